@@ -0,0 +1,287 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// AccountImport is an account to create with AccountsImport.
+type AccountImport struct {
+	Name             string   // Account name.
+	Address          string   // Initial email address, its domain is the default domain of the account.
+	FullName         string   `json:",omitempty"`
+	Addresses        []string `json:",omitempty"` // Additional addresses, "@domain" for a catchall address.
+	QuotaMessageSize int64    `json:",omitempty"` // If > 0, maximum total message size for the account. If < 0, no limit.
+	Password         string   `json:",omitempty"` // If empty, a random password is generated.
+}
+
+// AccountImportResult is the result for an account from AccountsImport.
+type AccountImportResult struct {
+	Name     string
+	Address  string
+	Password string // Initial password, generated if not specified. Empty for dry runs.
+	Error    string // If non-empty, the account was not valid, and no accounts were added.
+}
+
+// ParseAccountImports parses accounts to import from either JSON (an array of
+// AccountImport) or CSV. CSV lines have fields: name, address, full name,
+// additional addresses (space-separated), quota message size in bytes, password.
+// All fields after address are optional. A first line starting with field
+// "name" is treated as header and skipped. Lines starting with "#" are
+// comments.
+func ParseAccountImports(data []byte) ([]AccountImport, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var l []AccountImport
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&l); err != nil {
+			return nil, fmt.Errorf("%w: parsing json: %v", ErrRequest, err)
+		}
+		return l, nil
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	r.Comment = '#'
+	var l []AccountImport
+	for i := 0; ; i++ {
+		fields, err := r.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: parsing csv: %v", ErrRequest, err)
+		}
+		if i == 0 && strings.EqualFold(fields[0], "name") {
+			continue
+		}
+		line, _ := r.FieldPos(0)
+		if len(fields) < 2 || len(fields) > 6 {
+			return nil, fmt.Errorf("%w: line %d: got %d fields, expected 2 to 6", ErrRequest, line, len(fields))
+		}
+		ai := AccountImport{Name: fields[0], Address: fields[1]}
+		if len(fields) > 2 {
+			ai.FullName = fields[2]
+		}
+		if len(fields) > 3 {
+			ai.Addresses = strings.Fields(fields[3])
+		}
+		if len(fields) > 4 && fields[4] != "" {
+			ai.QuotaMessageSize, err = strconv.ParseInt(fields[4], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: line %d: parsing quota message size: %v", ErrRequest, line, err)
+			}
+		}
+		if len(fields) > 5 {
+			ai.Password = fields[5]
+		}
+		l = append(l, ai)
+	}
+	return l, nil
+}
+
+// AccountsImport adds multiple accounts, each with an initial address and
+// optional additional addresses. Either all accounts are added, or none: If any
+// account is not valid, an error is returned and the results have an error
+// for each invalid account. With dryRun, only the validity of the accounts is
+// checked. Otherwise the configuration is written and reloaded, and the initial
+// password of each account is set, generating one if none was specified. If
+// requireChange is set, the password must be changed by the account at the
+// first login through the account web interface.
+func AccountsImport(ctx context.Context, accounts []AccountImport, dryRun, requireChange bool) (results []AccountImportResult, rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("importing accounts", rerr, slog.Int("count", len(accounts)), slog.Bool("dryrun", dryRun))
+		}
+	}()
+
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("%w: no accounts", ErrRequest)
+	}
+
+	results = make([]AccountImportResult, len(accounts))
+	var invalid int
+
+	err := func() error {
+		defer mox.Conf.DynamicLockUnlock()()
+
+		c := mox.Conf.Dynamic
+
+		// Compose new config without modifying existing data structures. If we fail, we
+		// leave no trace.
+		nc := c
+		nc.Accounts = maps.Clone(c.Accounts)
+
+		// Addresses claimed by earlier accounts in this import.
+		seen := map[string]string{}
+
+		for i, ai := range accounts {
+			results[i] = AccountImportResult{Name: ai.Name, Address: ai.Address}
+			accConf, err := accountImportConfig(nc, ai, seen)
+			if err != nil {
+				results[i].Error = err.Error()
+				invalid++
+				continue
+			}
+			nc.Accounts[ai.Name] = accConf
+		}
+		if invalid > 0 {
+			return fmt.Errorf("%w: %d of %d accounts not valid", ErrRequest, invalid, len(accounts))
+		}
+
+		if dryRun {
+			if errs := mox.CheckDynamicConfig(ctx, log, &nc); len(errs) > 0 {
+				return fmt.Errorf("%w: invalid configuration: %v", ErrRequest, errors.Join(errs...))
+			}
+			return nil
+		}
+		if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+			return fmt.Errorf("writing domains.conf: %w", err)
+		}
+		return nil
+	}()
+	if err != nil || dryRun {
+		return results, err
+	}
+	log.Info("accounts imported", slog.Int("count", len(accounts)))
+
+	// The accounts now exist, set their passwords. Failures are reported per account.
+	var failed int
+	for i, ai := range accounts {
+		password := ai.Password
+		if password == "" {
+			password = mox.GeneratePassword()
+		}
+		if err := accountImportPassword(log, ai.Name, password, requireChange); err != nil {
+			results[i].Error = err.Error()
+			failed++
+			continue
+		}
+		results[i].Password = password
+	}
+	if failed > 0 {
+		return results, fmt.Errorf("setting password for %d of %d accounts failed", failed, len(accounts))
+	}
+	return results, nil
+}
+
+// accountImportConfig returns the configuration for a new account, checking
+// that it is valid in combination with the (new) config nc and the addresses
+// already claimed in this import, which is updated. Must be called with the
+// dynamic config lock held.
+func accountImportConfig(nc config.Dynamic, ai AccountImport, seen map[string]string) (config.Account, error) {
+	if ai.Name == "" {
+		return config.Account{}, fmt.Errorf("missing account name")
+	}
+	if _, ok := nc.Accounts[ai.Name]; ok {
+		return config.Account{}, fmt.Errorf("account already present")
+	}
+
+	// Ensure the directory does not exist, e.g. due to pending account removal, or an
+	// otherwise failed cleanup.
+	accountDir := filepath.Join(mox.DataDirPath("accounts"), ai.Name)
+	if _, err := os.Stat(accountDir); err == nil {
+		return config.Account{}, fmt.Errorf("account directory %q already/still exists", accountDir)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return config.Account{}, fmt.Errorf(`stat account directory %q, expected "does not exist": %v`, accountDir, err)
+	}
+
+	claim := func(destAddr string) error {
+		if other, ok := seen[destAddr]; ok {
+			return fmt.Errorf("address %s also used for account %q in import", destAddr, other)
+		}
+		seen[destAddr] = ai.Name
+		return nil
+	}
+
+	addr, err := smtp.ParseAddress(ai.Address)
+	if err != nil {
+		return config.Account{}, fmt.Errorf("parsing email address: %v", err)
+	}
+	if err := checkAddressAvailable(addr); err != nil {
+		return config.Account{}, fmt.Errorf("address not available: %v", err)
+	}
+	if err := claim(addr.String()); err != nil {
+		return config.Account{}, err
+	}
+
+	accConf := MakeAccountConfig(addr)
+	accConf.FullName = ai.FullName
+	accConf.QuotaMessageSize = ai.QuotaMessageSize
+
+	for _, address := range ai.Addresses {
+		var destAddr string
+		if strings.HasPrefix(address, "@") {
+			d, err := dns.ParseDomain(address[1:])
+			if err != nil {
+				return config.Account{}, fmt.Errorf("parsing domain of catchall address %q: %v", address, err)
+			}
+			destAddr = "@" + d.Name()
+			if _, ok := nc.Domains[d.Name()]; !ok {
+				return config.Account{}, fmt.Errorf("domain for catchall address %q does not exist", address)
+			} else if _, ok := mox.Conf.AccountDestinationsLocked[destAddr]; ok {
+				return config.Account{}, fmt.Errorf("catchall address already configured for domain %s", d)
+			}
+		} else {
+			a, err := smtp.ParseAddress(address)
+			if err != nil {
+				return config.Account{}, fmt.Errorf("parsing email address %q: %v", address, err)
+			}
+			if err := checkAddressAvailable(a); err != nil {
+				return config.Account{}, fmt.Errorf("address %s not available: %v", a, err)
+			}
+			destAddr = a.String()
+		}
+		if _, ok := accConf.Destinations[destAddr]; ok {
+			return config.Account{}, fmt.Errorf("duplicate address %s", destAddr)
+		}
+		if err := claim(destAddr); err != nil {
+			return config.Account{}, err
+		}
+		accConf.Destinations[destAddr] = config.Destination{}
+	}
+	return accConf, nil
+}
+
+// accountImportPassword sets the initial password for a new account.
+func accountImportPassword(log mlog.Log, accountName, password string, requireChange bool) error {
+	acc, err := store.OpenAccount(log, accountName, false)
+	if err != nil {
+		return fmt.Errorf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account after setting password")
+	}()
+
+	if err := acc.SetPassword(log, password); err != nil {
+		return fmt.Errorf("setting password: %v", err)
+	}
+	if requireChange {
+		if err := acc.PasswordRequireChange(log); err != nil {
+			return fmt.Errorf("requiring password change: %v", err)
+		}
+	}
+	return nil
+}
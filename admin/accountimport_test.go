@@ -0,0 +1,102 @@
+package admin
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+func TestAccountsImport(t *testing.T) {
+	dir := t.TempDir()
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	for _, name := range []string{"mox.conf", "domains.conf"} {
+		buf, err := os.ReadFile(filepath.FromSlash("../testdata/store/" + name))
+		tcheck(t, err, "read config")
+		err = os.WriteFile(filepath.Join(dir, name), buf, 0660)
+		tcheck(t, err, "write config")
+	}
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	csv := `name,address,fullname,addresses,quota,password
+# Comment.
+imp1,imp1@mox.example,"Import One",imp1b@mox.example,1000000,
+imp2,imp2@mox.example,,,,testtest123
+`
+	l, err := ParseAccountImports([]byte(csv))
+	tcheck(t, err, "parse csv")
+	if len(l) != 2 || l[0].FullName != "Import One" || len(l[0].Addresses) != 1 || l[0].QuotaMessageSize != 1000000 || l[1].Password != "testtest123" {
+		t.Fatalf("unexpected parsed accounts: %#v", l)
+	}
+	jl, err := ParseAccountImports([]byte(`[{"Name": "imp1", "Address": "imp1@mox.example", "Addresses": ["imp1b@mox.example"]}]`))
+	tcheck(t, err, "parse json")
+	if len(jl) != 1 || jl[0].Addresses[0] != "imp1b@mox.example" {
+		t.Fatalf("unexpected parsed json accounts: %#v", jl)
+	}
+	if _, err := ParseAccountImports([]byte("imp1\n")); !errors.Is(err, ErrRequest) {
+		t.Fatalf("parsing line with too few fields: got %v, expected ErrRequest", err)
+	}
+
+	// Invalid accounts: existing account, address in use, address claimed twice in import.
+	bad := append([]AccountImport{
+		{Name: "mjl", Address: "new@mox.example"},
+		{Name: "imp3", Address: "mjl@mox.example"},
+		{Name: "imp4", Address: "imp2@mox.example"},
+	}, l...)
+	results, err := AccountsImport(ctxbg, bad, true, false)
+	if !errors.Is(err, ErrRequest) {
+		t.Fatalf("import with invalid accounts: got %v, expected ErrRequest", err)
+	}
+	if len(results) != 5 || results[0].Error == "" || results[1].Error == "" || results[2].Error != "" || results[3].Error != "" || results[4].Error == "" {
+		t.Fatalf("unexpected results: %#v", results)
+	}
+	if _, ok := mox.Conf.Account("imp1"); ok {
+		t.Fatalf("account added for import with errors")
+	}
+
+	// Dry run does not add the accounts.
+	results, err = AccountsImport(ctxbg, l, true, false)
+	tcheck(t, err, "dry run")
+	if results[0].Password != "" {
+		t.Fatalf("password for dry run")
+	}
+	if _, ok := mox.Conf.Account("imp1"); ok {
+		t.Fatalf("account added for dry run")
+	}
+
+	results, err = AccountsImport(ctxbg, l, false, true)
+	tcheck(t, err, "import")
+	if results[0].Password == "" || results[1].Password != "testtest123" {
+		t.Fatalf("unexpected passwords in results: %#v", results)
+	}
+	accConf, ok := mox.Conf.Account("imp1")
+	if !ok || accConf.FullName != "Import One" || accConf.QuotaMessageSize != 1000000 || len(accConf.Destinations) != 2 {
+		t.Fatalf("unexpected config for imported account: %#v", accConf)
+	}
+
+	acc, err := store.OpenAccount(pkglog, "imp2", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		tcheck(t, err, "close account")
+		acc.WaitClosed()
+	}()
+	if err := acc.CheckPasswordLogin(); !errors.Is(err, store.ErrPasswordExpired) {
+		t.Fatalf("check password login: got %v, expected ErrPasswordExpired", err)
+	}
+
+	// Importing again fails, the accounts exist.
+	if _, err := AccountsImport(ctxbg, l, true, false); !errors.Is(err, ErrRequest) {
+		t.Fatalf("importing existing accounts: got %v, expected ErrRequest", err)
+	}
+}
@@ -380,8 +380,23 @@ func DKIMRemove(ctx context.Context, domain, selector dns.Domain) (rerr error) {
 		}
 	}
 
+	// Remove the selector from sign rules, dropping rules without selectors left.
+	var nrules []config.DKIMSignRule
+	for _, rule := range d.DKIM.SignRules {
+		var rsign []string
+		for _, name := range rule.Sign {
+			if name != selector.Name() {
+				rsign = append(rsign, name)
+			}
+		}
+		if len(rsign) > 0 {
+			rule.Sign = rsign
+			nrules = append(nrules, rule)
+		}
+	}
+
 	nd := d
-	nd.DKIM = config.DKIM{Selectors: nsels, Sign: nsign}
+	nd.DKIM = config.DKIM{Selectors: nsels, Sign: nsign, SignRules: nrules}
 	nc := c
 	nc.Domains = map[string]config.Domain{}
 	for name, dom := range c.Domains {
@@ -776,6 +791,13 @@ func AccountRemove(ctx context.Context, account string) (rerr error) {
 	return nil
 }
 
+// AddressAvailable returns an error if the address cannot be added to an account,
+// e.g. because its domain is not configured or the address is already in use.
+func AddressAvailable(addr smtp.Address) error {
+	defer mox.Conf.DynamicLockUnlock()()
+	return checkAddressAvailable(addr)
+}
+
 // checkAddressAvailable checks that the address after canonicalization is not
 // already configured, and that its localpart does not contain a catchall
 // localpart separator.
@@ -1111,6 +1133,78 @@ func AliasAddressesRemove(ctx context.Context, addr smtp.Address, addresses []st
 		if len(addresses) > 0 {
 			return fmt.Errorf("%w: address not found: %s", ErrRequest, strings.Join(addresses, ", "))
 		}
+		if len(alias.Members) > 0 {
+			alias.Members = maps.Clone(alias.Members)
+			maps.DeleteFunc(alias.Members, func(addr string, m config.AliasMember) bool {
+				return !slices.Contains(alias.Addresses, addr)
+			})
+			if len(alias.Members) == 0 {
+				alias.Members = nil
+			}
+		}
+		alias.ParsedAddresses = nil
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+}
+
+// AliasListSave saves the distribution list settings of an alias: the post
+// policy, whether list headers are added, and the owners.
+func AliasListSave(ctx context.Context, addr smtp.Address, postPolicy string, listHeaders bool, owners []string) error {
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		alias, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return fmt.Errorf("%w: no such alias", ErrRequest)
+		}
+		alias.PostPolicy = postPolicy
+		alias.ListHeaders = listHeaders
+		alias.Owners = owners
+		alias.ParsedOwners = nil
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+}
+
+// AliasMailingListSave saves the mailing list settings of an alias. A nil list
+// turns the alias back into a regular alias, keeping the subscribers in the
+// database.
+func AliasMailingListSave(ctx context.Context, addr smtp.Address, list *config.AliasList) error {
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		alias, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return fmt.Errorf("%w: no such alias", ErrRequest)
+		}
+		alias.List = list
+		d.Aliases = maps.Clone(d.Aliases)
+		d.Aliases[addr.Localpart.String()] = alias
+		return nil
+	})
+}
+
+// AliasMemberSave saves the delivery preferences of a member of an alias.
+func AliasMemberSave(ctx context.Context, addr smtp.Address, memberAddress string, member config.AliasMember) error {
+	return DomainSave(ctx, addr.Domain.Name(), func(d *config.Domain) error {
+		alias, ok := d.Aliases[addr.Localpart.String()]
+		if !ok {
+			return fmt.Errorf("%w: no such alias", ErrRequest)
+		}
+		if !slices.Contains(alias.Addresses, memberAddress) {
+			return fmt.Errorf("%w: address is not a member", ErrRequest)
+		}
+		alias.Members = maps.Clone(alias.Members)
+		if member == (config.AliasMember{}) {
+			delete(alias.Members, memberAddress)
+			if len(alias.Members) == 0 {
+				alias.Members = nil
+			}
+		} else {
+			if alias.Members == nil {
+				alias.Members = map[string]config.AliasMember{}
+			}
+			alias.Members[memberAddress] = member
+		}
 		alias.ParsedAddresses = nil
 		d.Aliases = maps.Clone(d.Aliases)
 		d.Aliases[addr.Localpart.String()] = alias
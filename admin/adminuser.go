@@ -0,0 +1,123 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/text/secure/precis"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+)
+
+func adminPasswordHash(password string) (string, error) {
+	pw, err := precis.OpaqueString.String(password)
+	if err != nil {
+		return "", fmt.Errorf("%w: password does not meet precis requirements: %v", ErrRequest, err)
+	}
+	if len(pw) < 8 {
+		return "", fmt.Errorf("%w: password must be at least 8 characters", ErrRequest)
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("generating password hash: %v", err)
+	}
+	return string(hash), nil
+}
+
+// adminUserSave modifies admin user name with xmodify and writes the config. If
+// add is set, the user must not exist yet, otherwise it must exist.
+func adminUserSave(ctx context.Context, name string, add bool, xmodify func(au *config.AdminUser)) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("saving admin user", rerr, slog.String("adminuser", name))
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	au, ok := c.AdminUsers[name]
+	if add && ok {
+		return fmt.Errorf("%w: admin user already present", ErrRequest)
+	} else if !add && !ok {
+		return fmt.Errorf("%w: admin user not present", ErrRequest)
+	}
+	xmodify(&au)
+
+	// Compose new config without modifying existing data structures.
+	nc := c
+	nc.AdminUsers = maps.Clone(c.AdminUsers)
+	if nc.AdminUsers == nil {
+		nc.AdminUsers = map[string]config.AdminUser{}
+	}
+	nc.AdminUsers[name] = au
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	log.Info("admin user saved", slog.String("adminuser", name), slog.String("role", au.Role), slog.Any("domains", au.Domains))
+	return nil
+}
+
+// AdminUserAdd adds a named user for the admin web interface, with a role, see
+// config.AdminRoleAdmin and friends, and domains for domain admins.
+func AdminUserAdd(ctx context.Context, name, role string, domains []string, password string) error {
+	hash, err := adminPasswordHash(password)
+	if err != nil {
+		return err
+	}
+	return adminUserSave(ctx, name, true, func(au *config.AdminUser) {
+		*au = config.AdminUser{Role: role, Domains: domains, PasswordHash: hash}
+	})
+}
+
+// AdminUserUpdate changes the role and domains of an admin user.
+func AdminUserUpdate(ctx context.Context, name, role string, domains []string) error {
+	return adminUserSave(ctx, name, false, func(au *config.AdminUser) {
+		au.Role = role
+		au.Domains = domains
+	})
+}
+
+// AdminUserSetPassword sets a new password for an admin user.
+func AdminUserSetPassword(ctx context.Context, name, password string) error {
+	hash, err := adminPasswordHash(password)
+	if err != nil {
+		return err
+	}
+	return adminUserSave(ctx, name, false, func(au *config.AdminUser) {
+		au.PasswordHash = hash
+	})
+}
+
+// AdminUserRemove removes an admin user. Existing sessions of the user are no
+// longer valid.
+func AdminUserRemove(ctx context.Context, name string) (rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("removing admin user", rerr, slog.String("adminuser", name))
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	if _, ok := c.AdminUsers[name]; !ok {
+		return fmt.Errorf("%w: admin user not present", ErrRequest)
+	}
+	nc := c
+	nc.AdminUsers = maps.Clone(c.AdminUsers)
+	delete(nc.AdminUsers, name)
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	log.Info("admin user removed", slog.String("adminuser", name))
+	return nil
+}
@@ -0,0 +1,281 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mjl-/sconf"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+)
+
+// ErrConfigChanged is returned when applying a config that is based on a config
+// that is no longer current.
+var ErrConfigChanged = errors.New("config changed")
+
+// DynamicConfig is the dynamic config in structured form and as text in
+// domains.conf format, with a hash of the text to detect concurrent changes.
+//
+// Admin users are left out. They are managed separately, and kept as is when
+// applying a config.
+type DynamicConfig struct {
+	Config config.Dynamic
+	Text   string
+	Hash   string // Hex SHA-256 of Text.
+}
+
+func makeDynamicConfig(c config.Dynamic) (DynamicConfig, error) {
+	c.AdminUsers = nil
+	var b bytes.Buffer
+	if err := sconf.Write(&b, c); err != nil {
+		return DynamicConfig{}, fmt.Errorf("writing config: %v", err)
+	}
+	text := b.String()
+	return DynamicConfig{c, text, fmt.Sprintf("%x", sha256.Sum256([]byte(text)))}, nil
+}
+
+// DynamicConfigGet returns the current dynamic config.
+func DynamicConfigGet() (DynamicConfig, error) {
+	return makeDynamicConfig(mox.Conf.DynamicConfig())
+}
+
+// DynamicConfigValidate checks c as it would be checked when applied, returning
+// the errors.
+func DynamicConfigValidate(ctx context.Context, c config.Dynamic) []error {
+	log := pkglog.WithContext(ctx)
+	c.AdminUsers = mox.Conf.DynamicConfig().AdminUsers
+	return mox.CheckDynamicConfig(ctx, log, &c)
+}
+
+// DynamicConfigDiff returns a unified diff of domains.conf between the current
+// config and c. Empty if there are no differences.
+func DynamicConfigDiff(c config.Dynamic) (string, error) {
+	cur, err := DynamicConfigGet()
+	if err != nil {
+		return "", err
+	}
+	ndc, err := makeDynamicConfig(c)
+	if err != nil {
+		return "", err
+	}
+	return unifiedDiff("domains.conf", "domains.conf (new)", cur.Text, ndc.Text), nil
+}
+
+// DynamicConfigApply validates c, and writes it to domains.conf and activates it.
+// baseHash must be the hash of the config that c was based on, ErrConfigChanged is
+// returned if the config has changed since.
+//
+// After writing, domains.conf is parsed again, as it would be after a restart. If
+// that fails, or writing failed, the previous config is restored.
+func DynamicConfigApply(ctx context.Context, baseHash string, c config.Dynamic) (ndc DynamicConfig, rerr error) {
+	log := pkglog.WithContext(ctx)
+	defer func() {
+		if rerr != nil {
+			log.Errorx("applying config", rerr)
+		}
+	}()
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	prev := mox.Conf.Dynamic
+	cur, err := makeDynamicConfig(prev)
+	if err != nil {
+		return DynamicConfig{}, err
+	}
+	if cur.Hash != baseHash {
+		return DynamicConfig{}, fmt.Errorf("%w since it was retrieved, get the current config and try again", ErrConfigChanged)
+	}
+	prevBuf, err := os.ReadFile(mox.ConfigDynamicPath)
+	if err != nil {
+		return DynamicConfig{}, fmt.Errorf("reading current domains.conf: %v", err)
+	}
+
+	c.AdminUsers = prev.AdminUsers
+	if err := mox.WriteDynamicLocked(ctx, log, c); err != nil {
+		if !errors.Is(err, mox.ErrConfig) {
+			// Writing may have failed halfway.
+			rerr := restoreFile(mox.ConfigDynamicPath, prevBuf)
+			log.Check(rerr, "restoring previous domains.conf after failed write")
+		}
+		return DynamicConfig{}, fmt.Errorf("writing domains.conf: %w", err)
+	}
+
+	if _, _, _, _, errs := mox.ParseDynamicConfig(ctx, log, mox.ConfigDynamicPath, mox.Conf.Static); len(errs) > 0 {
+		err := mox.WriteDynamicLocked(ctx, log, prev)
+		log.Check(err, "restoring previous config")
+		if err != nil {
+			rerr := restoreFile(mox.ConfigDynamicPath, prevBuf)
+			log.Check(rerr, "restoring previous domains.conf")
+		}
+		return DynamicConfig{}, fmt.Errorf("written domains.conf could not be loaded, restored previous config: %w", errors.Join(errs...))
+	}
+
+	return makeDynamicConfig(mox.Conf.Dynamic)
+}
+
+// restoreFile writes buf to the existing file at path.
+func restoreFile(path string, buf []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_TRUNC, 0660)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// unifiedDiff returns a unified diff with 3 lines of context between the lines
+// of a and b. Empty if they are the same.
+func unifiedDiff(nameA, nameB, a, b string) string {
+	la := diffLines(a)
+	lb := diffLines(b)
+
+	// Skip common prefix and suffix, then find the longest common subsequence of the
+	// remaining lines. Changes to configs are typically small.
+	pre := 0
+	for pre < len(la) && pre < len(lb) && la[pre] == lb[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(la)-pre && suf < len(lb)-pre && la[len(la)-1-suf] == lb[len(lb)-1-suf] {
+		suf++
+	}
+	ma := la[pre : len(la)-suf]
+	mb := lb[pre : len(lb)-suf]
+	if len(ma) == 0 && len(mb) == 0 {
+		return ""
+	}
+	lcs := make([][]int, len(ma)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(mb)+1)
+	}
+	for i := len(ma) - 1; i >= 0; i-- {
+		for j := len(mb) - 1; j >= 0; j-- {
+			if ma[i] == mb[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type op struct {
+		kind byte // ' ', '-' or '+'.
+		line string
+	}
+	var ops []op
+	for _, l := range la[:pre] {
+		ops = append(ops, op{' ', l})
+	}
+	i, j := 0, 0
+	for i < len(ma) || j < len(mb) {
+		if i < len(ma) && j < len(mb) && ma[i] == mb[j] {
+			ops = append(ops, op{' ', ma[i]})
+			i++
+			j++
+		} else if i < len(ma) && (j == len(mb) || lcs[i+1][j] >= lcs[i][j+1]) {
+			ops = append(ops, op{'-', ma[i]})
+			i++
+		} else {
+			ops = append(ops, op{'+', mb[j]})
+			j++
+		}
+	}
+	for _, l := range la[len(la)-suf:] {
+		ops = append(ops, op{' ', l})
+	}
+
+	const contextLines = 3
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", nameA, nameB)
+	var lineA, lineB int // Lines before ops[k].
+	for k := 0; k < len(ops); {
+		// Find next change, and the end of changes that are close together.
+		for k < len(ops) && ops[k].kind == ' ' {
+			k++
+			lineA++
+			lineB++
+		}
+		if k == len(ops) {
+			break
+		}
+		end := k
+		for e := k; e < len(ops); {
+			if ops[e].kind != ' ' {
+				e++
+				end = e
+				continue
+			}
+			n := e
+			for n < len(ops) && ops[n].kind == ' ' {
+				n++
+			}
+			if n == len(ops) || n-e > 2*contextLines {
+				break
+			}
+			e = n
+		}
+		start := max(0, k-contextLines)
+		stop := min(len(ops), end+contextLines)
+
+		startA := lineA - (k - start)
+		startB := lineB - (k - start)
+		var countA, countB int
+		var hunk strings.Builder
+		for _, o := range ops[start:stop] {
+			if o.kind != '+' {
+				countA++
+			}
+			if o.kind != '-' {
+				countB++
+			}
+			hunk.WriteByte(o.kind)
+			hunk.WriteString(o.line)
+		}
+		// Empty ranges start at the line before.
+		if countA > 0 {
+			startA++
+		}
+		if countB > 0 {
+			startB++
+		}
+		fmt.Fprintf(&out, "@@ -%d,%d +%d,%d @@\n%s", startA, countA, startB, countB, hunk.String())
+
+		for _, o := range ops[k:stop] {
+			if o.kind != '+' {
+				lineA++
+			}
+			if o.kind != '-' {
+				lineB++
+			}
+		}
+		k = stop
+	}
+	return out.String()
+}
+
+// diffLines returns the lines of s, each ending in a newline.
+func diffLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	l := strings.SplitAfter(s, "\n")
+	if l[len(l)-1] == "" {
+		l = l[:len(l)-1]
+	} else {
+		l[len(l)-1] += "\n"
+	}
+	return l
+}
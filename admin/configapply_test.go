@@ -0,0 +1,117 @@
+package admin
+
+import (
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+func TestDynamicConfigApply(t *testing.T) {
+	dir := t.TempDir()
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	for _, name := range []string{"mox.conf", "domains.conf"} {
+		buf, err := os.ReadFile(filepath.FromSlash("../testdata/store/" + name))
+		tcheck(t, err, "read config")
+		err = os.WriteFile(filepath.Join(dir, name), buf, 0660)
+		tcheck(t, err, "write config")
+	}
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	cur, err := DynamicConfigGet()
+	tcheck(t, err, "get config")
+	if cur.Hash == "" || !strings.Contains(cur.Text, "mox.example") {
+		t.Fatalf("bad config text or hash: %#v", cur)
+	}
+	if diff, err := DynamicConfigDiff(cur.Config); err != nil || diff != "" {
+		t.Fatalf("diff for unchanged config: %q, %v", diff, err)
+	}
+
+	// Config without postmaster account is invalid, and cannot be applied.
+	bad := cur.Config
+	bad.Accounts = map[string]config.Account{}
+	if errs := DynamicConfigValidate(ctxbg, bad); len(errs) == 0 {
+		t.Fatalf("no validation errors for invalid config")
+	}
+	if _, err := DynamicConfigApply(ctxbg, cur.Hash, bad); err == nil {
+		t.Fatalf("applied invalid config")
+	}
+
+	// Add an account.
+	nc := cur.Config
+	nc.Accounts = maps.Clone(nc.Accounts)
+	nc.Accounts["other"] = config.Account{Domain: "mox.example"}
+	if errs := DynamicConfigValidate(ctxbg, nc); len(errs) != 0 {
+		t.Fatalf("validation errors for valid config: %v", errs)
+	}
+	diff, err := DynamicConfigDiff(nc)
+	tcheck(t, err, "diff")
+	if !strings.HasPrefix(diff, "--- domains.conf\n+++ domains.conf (new)\n@@ ") || !strings.Contains(diff, "\n+\tother:\n") {
+		t.Fatalf("unexpected diff:\n%s", diff)
+	}
+
+	if _, err := DynamicConfigApply(ctxbg, "bogus", nc); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("apply with stale hash: got %v, expected ErrConfigChanged", err)
+	}
+	ndc, err := DynamicConfigApply(ctxbg, cur.Hash, nc)
+	tcheck(t, err, "apply config")
+	if ndc.Hash == cur.Hash {
+		t.Fatalf("hash did not change after apply")
+	}
+	if _, ok := mox.Conf.Account("other"); !ok {
+		t.Fatalf("new account not active")
+	}
+	buf, err := os.ReadFile(mox.ConfigDynamicPath)
+	tcheck(t, err, "read domains.conf")
+	if !strings.Contains(string(buf), "\tother:\n") {
+		t.Fatalf("new account not in domains.conf")
+	}
+
+	// Base hash is now stale.
+	if _, err := DynamicConfigApply(ctxbg, cur.Hash, cur.Config); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("apply with previous hash: got %v, expected ErrConfigChanged", err)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	a := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n13\n14\n15\n"
+	b := "0\n1\n2\n3\n4\n5\n6\n7\nx\n9\n10\n11\n12\n13\n14\n15\n16\n"
+	exp := `--- a
++++ b
+@@ -1,3 +1,4 @@
++0
+ 1
+ 2
+ 3
+@@ -5,7 +6,7 @@
+ 5
+ 6
+ 7
+-8
++x
+ 9
+ 10
+ 11
+@@ -13,3 +14,4 @@
+ 13
+ 14
+ 15
++16
+`
+	if diff := unifiedDiff("a", "b", a, b); diff != exp {
+		t.Fatalf("got diff:\n%s\nexpected:\n%s", diff, exp)
+	}
+}
@@ -0,0 +1,191 @@
+package admin
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+)
+
+// DANEKey is a TLS key of the mail host with its DANE TLSA record, for a current
+// certificate, for new certificates, or configured in HostPrivateKeyFiles.
+type DANEKey struct {
+	KeyType    string     // E.g. "ecdsa-p256" or "rsa-2048".
+	TLSA       string     // TLSA record data: DANE-EE, SPKI, SHA2-256 hash.
+	Current    bool       // Key of a certificate currently in use for the host.
+	Expires    *time.Time // Expiration of the current certificate.
+	Next       bool       // Key used when requesting new certificates through ACME.
+	Configured bool       // Key is in HostPrivateKeyFiles.
+	Published  bool       // Whether a TLSA record for the key was found in DNS.
+}
+
+// DANEHost is the status of the DANE TLSA records of the mail host, compared
+// against the keys of the current and next TLS certificates.
+type DANEHost struct {
+	Host      string // Host name of the public listener.
+	Name      string // DNS name of the TLSA records, e.g. _25._tcp.mail.example.com.
+	Authentic bool   // Whether the TLSA lookup was DNSSEC-verified. DANE is only in effect if so.
+	Keys      []DANEKey
+	Published []string // TLSA records found in DNS.
+	Records   []string // Suggested TLSA records: for keys of current certificates and configured keys.
+	Errors    []string
+	Warnings  []string
+}
+
+func daneKeyType(pubKey crypto.PublicKey) string {
+	switch k := pubKey.(type) {
+	case *ecdsa.PublicKey:
+		return "ecdsa-" + strings.ToLower(strings.ReplaceAll(k.Curve.Params().Name, "-", ""))
+	case *rsa.PublicKey:
+		return fmt.Sprintf("rsa-%d", k.N.BitLen())
+	}
+	return fmt.Sprintf("%T", pubKey)
+}
+
+// DANEHostStatus returns the TLSA records for the keys of the current and next
+// certificates of the mail host, as used for incoming SMTP, and verifies them
+// against the TLSA records in DNS. Rotating to a key without published TLSA record
+// breaks incoming deliveries from servers that verify with DANE, such situations
+// are returned as errors.
+func DANEHostStatus(ctx context.Context, resolver dns.Resolver) (DANEHost, error) {
+	public, ok := mox.Conf.Static.Listeners["public"]
+	host := public.HostnameDomain
+	if host.IsZero() {
+		host = mox.Conf.Static.HostnameDomain
+	}
+	r := DANEHost{Host: host.Name(), Name: "_25._tcp." + host.ASCII + "."}
+	if !ok || public.TLS == nil {
+		return r, fmt.Errorf("%w: no public listener with tls", ErrRequest)
+	}
+
+	addErrorf := func(format string, args ...any) {
+		r.Errors = append(r.Errors, fmt.Sprintf(format, args...))
+	}
+	addWarningf := func(format string, args ...any) {
+		r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+	}
+
+	// Keys by TLSA record, in order of r.Keys.
+	keys := map[string]int{}
+	addKey := func(pubKey crypto.PublicKey) (*DANEKey, error) {
+		record, err := mox.DANERecord(pubKey)
+		if err != nil {
+			return nil, err
+		}
+		s := record.Record()
+		if i, ok := keys[s]; ok {
+			return &r.Keys[i], nil
+		}
+		keys[s] = len(r.Keys)
+		r.Keys = append(r.Keys, DANEKey{KeyType: daneKeyType(pubKey), TLSA: s})
+		return &r.Keys[len(r.Keys)-1], nil
+	}
+
+	// Configured keys. The first of each type is used for new certificates through ACME.
+	for _, l := range [][]crypto.Signer{public.TLS.HostPrivateECDSAP256Keys, public.TLS.HostPrivateRSA2048Keys} {
+		for i, privKey := range l {
+			dk, err := addKey(privKey.Public())
+			if err != nil {
+				return r, err
+			}
+			dk.Configured = true
+			dk.Next = i == 0 && public.TLS.ACME != ""
+		}
+	}
+
+	// Keys of current certificates.
+	var blockRollover bool
+	if public.TLS.ACME != "" {
+		acme := mox.Conf.Static.ACME[public.TLS.ACME]
+		blockRollover = acme.BlockDANERollover
+		if acme.Manager != nil {
+			for _, isRSA := range []bool{false, true} {
+				_, cert, err := acme.Manager.CachedKeyCert(ctx, host, isRSA)
+				if err != nil {
+					addErrorf("Getting current certificate from acme cache: %v", err)
+					continue
+				} else if cert == nil {
+					continue
+				}
+				dk, err := addKey(cert.PublicKey)
+				if err != nil {
+					return r, err
+				}
+				dk.Current = true
+				dk.Expires = &cert.NotAfter
+			}
+		}
+		if len(public.TLS.HostPrivateECDSAP256Keys) == 0 && len(public.TLS.HostPrivateRSA2048Keys) == 0 {
+			addWarningf("No HostPrivateKeyFiles configured, new certificates requested through ACME get new keys. Configure static host private keys before publishing TLSA records.")
+		}
+	} else {
+		for _, cert := range mox.TLSCertificates(public.TLS) {
+			if cert.Leaf == nil || cert.Leaf.VerifyHostname(host.ASCII) != nil {
+				continue
+			}
+			dk, err := addKey(cert.Leaf.PublicKey)
+			if err != nil {
+				return r, err
+			}
+			dk.Current = true
+			dk.Expires = &cert.Leaf.NotAfter
+		}
+	}
+
+	for _, dk := range r.Keys {
+		if dk.Current || dk.Configured {
+			r.Records = append(r.Records, dk.TLSA)
+		}
+	}
+
+	tlsal, result, err := resolver.LookupTLSA(ctx, 25, "tcp", host.ASCII+".")
+	if err != nil && !dns.IsNotFound(err) {
+		addErrorf("Looking up TLSA records: %v", err)
+		return r, nil
+	}
+	r.Authentic = result.Authentic
+	var unknown []string
+	for _, t := range tlsal {
+		s := t.Record()
+		r.Published = append(r.Published, s)
+		if i, ok := keys[s]; ok {
+			r.Keys[i].Published = true
+		} else {
+			unknown = append(unknown, s)
+		}
+	}
+	if len(tlsal) == 0 {
+		addWarningf("No TLSA records published, DANE is not in effect.")
+		return r, nil
+	} else if !result.Authentic {
+		addWarningf("TLSA records are not DNSSEC-signed, DANE is not in effect.")
+	}
+
+	for _, dk := range r.Keys {
+		if dk.Published {
+			continue
+		}
+		if dk.Current {
+			addErrorf("No TLSA record published for the key of a current certificate, remote servers verifying with DANE fail to deliver. Publish TLSA record %s.", dk.TLSA)
+		} else if dk.Next {
+			s := fmt.Sprintf("No TLSA record published for the key of the next certificate, renewing the certificate would break delivery from remote servers verifying with DANE. Publish TLSA record %s, and wait for the TTL of the current records to expire before the certificate is renewed.", dk.TLSA)
+			if blockRollover {
+				addWarningf("%s Until then, renewals keep using the key of the current certificate.", s)
+			} else {
+				addErrorf("%s Or enable BlockDANERollover for the ACME provider.", s)
+			}
+		} else if dk.Configured {
+			addWarningf("No TLSA record published for configured key %s, with record %s. Publish the record before using the key.", dk.KeyType, dk.TLSA)
+		}
+	}
+	for _, s := range unknown {
+		addWarningf("Published TLSA record %s does not match a current or configured key, it can be removed.", s)
+	}
+	return r, nil
+}
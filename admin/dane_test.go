@@ -0,0 +1,84 @@
+package admin
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/adns"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestDANEHostStatus(t *testing.T) {
+	origHostname, origListeners, origACME := mox.Conf.Static.HostnameDomain, mox.Conf.Static.Listeners, mox.Conf.Static.ACME
+	defer func() {
+		mox.Conf.Static.HostnameDomain, mox.Conf.Static.Listeners, mox.Conf.Static.ACME = origHostname, origListeners, origACME
+	}()
+
+	genKey := func() crypto.Signer {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+		tcheck(t, err, "generate key")
+		return k
+	}
+	key0, key1 := genKey(), genKey()
+	record := func(k crypto.Signer) adns.TLSA {
+		r, err := mox.DANERecord(k.Public())
+		tcheck(t, err, "dane record")
+		return r
+	}
+
+	mox.Conf.Static.HostnameDomain = dns.Domain{ASCII: "mail.mox.example"}
+	mox.Conf.Static.ACME = map[string]config.ACME{"test": {}}
+	mox.Conf.Static.Listeners = map[string]config.Listener{
+		"public": {TLS: &config.TLS{ACME: "test", HostPrivateECDSAP256Keys: []crypto.Signer{key0, key1}}},
+	}
+
+	// Only the second key is published, the first is used for the next certificate.
+	other := adns.TLSA{Usage: adns.TLSAUsageDANEEE, Selector: adns.TLSASelectorSPKI, MatchType: adns.TLSAMatchTypeSHA256, CertAssoc: make([]byte, 32)}
+	resolver := dns.MockResolver{
+		TLSA:         map[string][]adns.TLSA{"_25._tcp.mail.mox.example.": {record(key1), other}},
+		AllAuthentic: true,
+	}
+	r, err := DANEHostStatus(ctxbg, resolver)
+	tcheck(t, err, "dane host status")
+	if !r.Authentic || len(r.Keys) != 2 || !r.Keys[0].Next || r.Keys[0].Published || r.Keys[1].Next || !r.Keys[1].Published {
+		t.Fatalf("unexpected status %#v", r)
+	}
+	if !slices.Equal(r.Records, []string{record(key0).Record(), record(key1).Record()}) {
+		t.Fatalf("unexpected suggested records %v", r.Records)
+	}
+	if len(r.Errors) != 1 || !strings.Contains(r.Errors[0], "next certificate") {
+		t.Fatalf("unexpected errors %v", r.Errors)
+	}
+	if len(r.Warnings) != 1 || !strings.Contains(r.Warnings[0], other.Record()) {
+		t.Fatalf("unexpected warnings %v", r.Warnings)
+	}
+
+	// With rollover blocked, a missing record for the next key is a warning.
+	mox.Conf.Static.ACME = map[string]config.ACME{"test": {BlockDANERollover: true}}
+	r, err = DANEHostStatus(ctxbg, resolver)
+	tcheck(t, err, "dane host status")
+	if len(r.Errors) != 0 || len(r.Warnings) != 2 {
+		t.Fatalf("unexpected errors %v, warnings %v", r.Errors, r.Warnings)
+	}
+
+	// Without DNSSEC, DANE is not in effect.
+	resolver.AllAuthentic = false
+	r, err = DANEHostStatus(ctxbg, resolver)
+	tcheck(t, err, "dane host status")
+	if r.Authentic || !slices.ContainsFunc(r.Warnings, func(s string) bool { return strings.Contains(s, "not DNSSEC-signed") }) {
+		t.Fatalf("unexpected status %#v", r)
+	}
+
+	mox.Conf.Static.Listeners = map[string]config.Listener{}
+	if _, err := DANEHostStatus(ctxbg, resolver); err == nil {
+		t.Fatalf("expected error without public listener")
+	}
+}
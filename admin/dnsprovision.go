@@ -0,0 +1,642 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/dnsprovision"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+// DNSProvisionRecords returns the DNS records managed by mox for a domain with
+// DNS provisioning: DKIM records for all selectors, SPF records for the domain
+// and mail host, the MTA-STS record, and TLSA records for the mail host. Records
+// outside the zone of the domain are skipped.
+func DNSProvisionRecords(domConf config.Domain, domain dns.Domain) ([]dnsprovision.Record, error) {
+	dp := domConf.DNSProvisioning
+	if dp == nil {
+		return nil, fmt.Errorf("%w: domain does not have dns provisioning configured", ErrRequest)
+	}
+	d := domain.ASCII
+	h := mox.Conf.Static.HostnameDomain.ASCII
+	inZone := func(name string) bool {
+		return name == dp.DNSZone.ASCII || strings.HasSuffix(name, "."+dp.DNSZone.ASCII)
+	}
+
+	var records []dnsprovision.Record
+
+	selectors := slices.Sorted(maps.Keys(domConf.DKIM.Selectors))
+	for _, name := range selectors {
+		txt, err := dkimRecordTXT(name, domConf.DKIM.Selectors[name])
+		if err != nil {
+			return nil, err
+		}
+		sel := domConf.DKIM.Selectors[name]
+		records = append(records, dnsprovision.Record{Name: sel.Domain.ASCII + "._domainkey." + d, Type: "TXT", Values: []string{txt}})
+	}
+
+	spftxt, err := domainSPFTXT()
+	if err != nil {
+		return nil, err
+	}
+	records = append(records, dnsprovision.Record{Name: d, Type: "TXT", Values: []string{spftxt}, Prefix: "v=spf1 "})
+	if d != h && inZone(h) {
+		records = append(records, dnsprovision.Record{Name: h, Type: "TXT", Values: []string{"v=spf1 a -all"}, Prefix: "v=spf1 "})
+	}
+
+	if sts := domConf.MTASTS; sts != nil {
+		records = append(records, dnsprovision.Record{Name: "_mta-sts." + d, Type: "TXT", Values: []string{"v=STSv1; id=" + sts.PolicyID}})
+	}
+
+	if b := domConf.BIMI; b != nil {
+		records = append(records, dnsprovision.Record{Name: b.SelectorEffective + "._bimi." + d, Type: "TXT", Values: []string{bimiRecord(*b, domain).String()}})
+	}
+
+	if public, ok := mox.Conf.Static.Listeners["public"]; ok && public.TLS != nil && inZone(h) {
+		tlsaRecords, err := hostTLSARecords(public)
+		if err != nil {
+			return nil, err
+		}
+		if len(tlsaRecords) > 0 {
+			r := dnsprovision.Record{Name: "_25._tcp." + h, Type: "TLSA"}
+			for _, t := range tlsaRecords {
+				r.Values = append(r.Values, t.Record())
+			}
+			records = append(records, r)
+		}
+	}
+
+	return slices.DeleteFunc(records, func(r dnsprovision.Record) bool { return !inZone(r.Name) }), nil
+}
+
+// dnsProvider returns the DNS provider for a domain.
+func dnsProvider(domConf config.Domain) (dnsprovision.Provider, error) {
+	dp := domConf.DNSProvisioning
+	if dp == nil {
+		return nil, fmt.Errorf("%w: domain does not have dns provisioning configured", ErrRequest)
+	}
+	pc, ok := mox.Conf.Static.DNSProviders[dp.Provider]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown dns provider %q", ErrRequest, dp.Provider)
+	}
+	return dnsprovision.New(pc)
+}
+
+func dnsProvisionTTL(dp *config.DNSProvisioning) int {
+	if dp.TTL > 0 {
+		return dp.TTL
+	}
+	return 300
+}
+
+// DNSProvision creates and updates the DNS records for a domain through its
+// DNS provider, returning the changed records.
+func DNSProvision(ctx context.Context, domain dns.Domain) (changed []string, rerr error) {
+	log := pkglog.WithContext(ctx)
+
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return nil, fmt.Errorf("%w: domain does not exist", ErrRequest)
+	}
+	records, err := DNSProvisionRecords(domConf, domain)
+	if err != nil {
+		return nil, err
+	}
+	p, err := dnsProvider(domConf)
+	if err != nil {
+		return nil, err
+	}
+	dp := domConf.DNSProvisioning
+	return dnsprovision.Sync(ctx, log, p, dp.DNSZone, dnsProvisionTTL(dp), records)
+}
+
+// DNSProvisionStart starts a goroutine that periodically (hourly) updates DNS
+// records of domains with DNS provisioning, and rotates DKIM keys of domains
+// with DKIM rotation configured.
+func DNSProvisionStart() {
+	go func() {
+		log := mlog.New("admin", nil)
+		resolver := dns.StrictResolver{Pkg: "admin", Log: log.Logger}
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Dnsprovision)
+			}
+		}()
+
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+		for {
+			select {
+			case <-mox.Shutdown.Done():
+				return
+			case <-timer.C:
+			}
+
+			for _, name := range mox.Conf.Domains() {
+				d, err := dns.ParseDomain(name)
+				if err != nil {
+					continue
+				}
+				domConf, ok := mox.Conf.Domain(d)
+				if !ok || domConf.DNSProvisioning == nil && DKIMRotationConfig(domConf) == nil {
+					continue
+				}
+				ctx, cancel := context.WithTimeout(context.WithValue(mox.Shutdown, mlog.CidKey, mox.Cid()), 5*time.Minute)
+				if domConf.DNSProvisioning != nil {
+					_, err = DNSProvision(ctx, d)
+					log.Check(err, "provisioning dns records", slog.Any("domain", d))
+				}
+				if DKIMRotationConfig(domConf) != nil {
+					err = DKIMRotate(ctx, resolver, d, time.Now())
+					log.Check(err, "rotating dkim keys", slog.Any("domain", d))
+				}
+				cancel()
+			}
+			timer.Reset(time.Hour)
+		}
+	}()
+}
+
+// DKIMRotationConfig returns the DKIM rotation configuration for a domain, from
+// either the DKIM or DNS provisioning configuration, or nil if rotation is not
+// configured.
+func DKIMRotationConfig(domConf config.Domain) *config.DKIMRotation {
+	if domConf.DKIM.Rotation != nil {
+		return domConf.DKIM.Rotation
+	}
+	if dp := domConf.DNSProvisioning; dp != nil {
+		return dp.DKIMRotation
+	}
+	return nil
+}
+
+// DKIMRotationPeriods returns the interval between rotations, the delay before
+// signing with new selectors, and the period old selectors are kept.
+func DKIMRotationPeriods(r *config.DKIMRotation) (interval, publishDelay, retainPeriod time.Duration) {
+	interval, publishDelay, retainPeriod = 90*24*time.Hour, 48*time.Hour, 7*24*time.Hour
+	if r.Interval > 0 {
+		interval = r.Interval
+	}
+	if r.PublishDelay > 0 {
+		publishDelay = r.PublishDelay
+	}
+	if r.RetainPeriod > 0 {
+		retainPeriod = r.RetainPeriod
+	}
+	return
+}
+
+// dkimSigning returns the selectors used for signing, in Sign and SignRules.
+func dkimSigning(d config.DKIM) []string {
+	l := slices.Clone(d.Sign)
+	for _, rule := range d.SignRules {
+		for _, name := range rule.Sign {
+			if !slices.Contains(l, name) {
+				l = append(l, name)
+			}
+		}
+	}
+	return l
+}
+
+// DKIMRotate moves the DKIM key rotation of a domain to its next stage if it is
+// due at time now:
+//
+//  1. An interval after the previous rotation, a new selector is added for each
+//     selector used for signing, with the same settings and type of key, and
+//     published in DNS. Without DNS provisioning, the administrator must add
+//     the DNS records.
+//  2. After the publish delay, if the new selectors are present in DNS, signing
+//     switches from the old to the new selectors. With DNS provisioning, the
+//     records are checked at the DNS provider, otherwise they are looked up with
+//     resolver.
+//  3. After the retain period, the old selectors are removed from the
+//     configuration and from DNS. Without DNS provisioning, the administrator
+//     must remove the DNS records.
+//
+// Each stage is recorded in the rotation history of the domain.
+func DKIMRotate(ctx context.Context, resolver dns.Resolver, domain dns.Domain, now time.Time) error {
+	log := pkglog.WithContext(ctx)
+
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return fmt.Errorf("%w: domain does not exist", ErrRequest)
+	}
+	rc := DKIMRotationConfig(domConf)
+	if rc == nil {
+		return fmt.Errorf("%w: domain does not have dkim rotation configured", ErrRequest)
+	}
+	dp := domConf.DNSProvisioning
+	interval, publishDelay, retainPeriod := DKIMRotationPeriods(rc)
+
+	q := bstore.QueryDB[store.DKIMRotation](ctx, store.AuthDB)
+	q.FilterNonzero(store.DKIMRotation{Domain: domain.Name()})
+	r, err := q.Get()
+	if errors.Is(err, bstore.ErrAbsent) {
+		// Start of first interval.
+		r = store.DKIMRotation{Domain: domain.Name(), Rotated: now}
+		if err := store.AuthDB.Insert(ctx, &r); err != nil {
+			return fmt.Errorf("storing dkim rotation state: %v", err)
+		}
+		dkimRotationEvent(ctx, domain, now, "started", dkimSigning(domConf.DKIM), fmt.Sprintf("Rotation enabled, first rotation after %s.", now.Add(interval).Format(time.RFC3339)))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("get dkim rotation state: %v", err)
+	}
+
+	switch {
+	case r.Added.IsZero():
+		if now.Sub(r.Rotated) < interval {
+			return nil
+		}
+		old := dkimSigning(domConf.DKIM)
+		if len(old) == 0 {
+			return nil
+		}
+		nsels, err := dkimRotateAdd(ctx, domain, domConf, old, now)
+		if err != nil {
+			return err
+		}
+		r.Added = now
+		r.Old = old
+		r.New = nsels
+		if err := store.AuthDB.Update(ctx, &r); err != nil {
+			return fmt.Errorf("storing dkim rotation state: %v", err)
+		}
+		log.Info("dkim rotation: new selectors added", slog.Any("domain", domain), slog.Any("old", old), slog.Any("new", nsels))
+
+		if dp == nil {
+			text := "New selectors added, replacing " + strings.Join(old, ", ") + ". Add their DNS records:"
+			if nc, ok := mox.Conf.Domain(domain); ok {
+				for _, name := range nsels {
+					if txt, err := dkimRecordTXT(name, nc.DKIM.Selectors[name]); err == nil {
+						text += fmt.Sprintf("\n%s._domainkey.%s TXT %s", name, domain.ASCII, txt)
+					}
+				}
+			}
+			dkimRotationEvent(ctx, domain, now, "added", nsels, text)
+			return nil
+		}
+		dkimRotationEvent(ctx, domain, now, "added", nsels, "New selectors added and published in DNS, replacing "+strings.Join(old, ", ")+".")
+
+		// Publish the new selectors.
+		_, err = DNSProvision(ctx, domain)
+		return err
+
+	case r.Switched.IsZero():
+		if now.Sub(r.Added) < publishDelay {
+			return nil
+		}
+		// Only switch when the new selectors are in DNS, publishing them again if needed.
+		if dp == nil {
+			for _, name := range r.New {
+				if ok, err := dkimSelectorInDNS(ctx, resolver, domain, name, domConf.DKIM.Selectors[name]); err != nil {
+					return fmt.Errorf("checking dns records for new selector: %v", err)
+				} else if !ok {
+					log.Info("dkim rotation: new selector not yet in dns, not switching", slog.Any("domain", domain), slog.String("selector", name))
+					return nil
+				}
+			}
+		} else {
+			p, err := dnsProvider(domConf)
+			if err != nil {
+				return err
+			}
+			for _, name := range r.New {
+				values, err := p.Records(ctx, dp.DNSZone, name+"._domainkey."+domain.ASCII, "TXT")
+				if err != nil {
+					return fmt.Errorf("checking dns records for new selector: %v", err)
+				} else if len(values) == 0 {
+					log.Info("dkim rotation: new selector not yet in dns, not switching", slog.Any("domain", domain), slog.String("selector", name))
+					_, err := DNSProvision(ctx, domain)
+					return err
+				}
+			}
+		}
+		if err := dkimSignReplace(ctx, domain, r.Old, r.New); err != nil {
+			return err
+		}
+		r.Switched = now
+		if err := store.AuthDB.Update(ctx, &r); err != nil {
+			return fmt.Errorf("storing dkim rotation state: %v", err)
+		}
+		log.Info("dkim rotation: signing with new selectors", slog.Any("domain", domain), slog.Any("selectors", r.New))
+		dkimRotationEvent(ctx, domain, now, "switched", r.New, "New selectors found in DNS, signing switched from "+strings.Join(r.Old, ", ")+".")
+		return nil
+
+	default:
+		if now.Sub(r.Switched) < retainPeriod {
+			return nil
+		}
+		var p dnsprovision.Provider
+		if dp != nil {
+			p, err = dnsProvider(domConf)
+			if err != nil {
+				return err
+			}
+		}
+		for _, name := range r.Old {
+			if _, ok := domConf.DKIM.Selectors[name]; ok {
+				sel, err := dns.ParseDomain(name)
+				if err != nil {
+					return fmt.Errorf("parsing selector: %v", err)
+				}
+				if err := DKIMRemove(ctx, domain, sel); err != nil {
+					return err
+				}
+			}
+			if p == nil {
+				continue
+			}
+			if err := p.SetRecords(ctx, dp.DNSZone, name+"._domainkey."+domain.ASCII, "TXT", dnsProvisionTTL(dp), nil); err != nil {
+				return fmt.Errorf("removing dns record for old selector: %v", err)
+			}
+		}
+		log.Info("dkim rotation: old selectors removed", slog.Any("domain", domain), slog.Any("selectors", r.Old))
+		text := "Old selectors removed from configuration and DNS."
+		var removeRecords []string
+		if p == nil {
+			text = "Old selectors removed from configuration. Remove their DNS records:"
+			for _, name := range r.Old {
+				text += fmt.Sprintf("\n%s._domainkey.%s TXT", name, domain.ASCII)
+			}
+			removeRecords = append(slices.Clone(r.RemoveRecords), r.Old...)
+		}
+		dkimRotationEvent(ctx, domain, now, "removed", r.Old, text)
+		r = store.DKIMRotation{ID: r.ID, Domain: r.Domain, Rotated: now, RemoveRecords: removeRecords}
+		if err := store.AuthDB.Update(ctx, &r); err != nil {
+			return fmt.Errorf("storing dkim rotation state: %v", err)
+		}
+		return nil
+	}
+}
+
+// dkimRotationEvent adds an entry to the rotation history of a domain. Errors are
+// logged, not returned.
+func dkimRotationEvent(ctx context.Context, domain dns.Domain, now time.Time, kind string, selectors []string, text string) {
+	e := store.DKIMRotationEvent{Domain: domain.Name(), Time: now, Kind: kind, Selectors: selectors, Text: text}
+	err := store.AuthDB.Insert(ctx, &e)
+	pkglog.WithContext(ctx).Check(err, "adding dkim rotation event", slog.Any("domain", domain))
+}
+
+// dkimSelectorInDNS returns whether the DKIM DNS record for selector name is
+// found with resolver, with the public key of sel.
+func dkimSelectorInDNS(ctx context.Context, resolver dns.Resolver, domain dns.Domain, name string, sel config.Selector) (bool, error) {
+	txt, err := dkimRecordTXT(name, sel)
+	if err != nil {
+		return false, err
+	}
+	exp, _, err := dkim.ParseRecord(txt)
+	if err != nil {
+		return false, fmt.Errorf("parsing expected dkim record: %v", err)
+	}
+	txts, _, err := resolver.LookupTXT(ctx, name+"._domainkey."+domain.ASCII+".")
+	if err != nil && !dns.IsNotFound(err) {
+		return false, err
+	}
+	for _, s := range txts {
+		if r, _, err := dkim.ParseRecord(s); err == nil && bytes.Equal(r.Pubkey, exp.Pubkey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// DKIMRotationRecordsRemoved clears the list of DNS records of old selectors the
+// administrator must remove, after the administrator has removed them.
+func DKIMRotationRecordsRemoved(ctx context.Context, domain dns.Domain) error {
+	q := bstore.QueryDB[store.DKIMRotation](ctx, store.AuthDB)
+	q.FilterNonzero(store.DKIMRotation{Domain: domain.Name()})
+	r, err := q.Get()
+	if errors.Is(err, bstore.ErrAbsent) {
+		return fmt.Errorf("%w: no dkim rotation for domain", ErrRequest)
+	} else if err != nil {
+		return fmt.Errorf("get dkim rotation state: %v", err)
+	}
+	if len(r.RemoveRecords) == 0 {
+		return nil
+	}
+	sels := r.RemoveRecords
+	r.RemoveRecords = nil
+	if err := store.AuthDB.Update(ctx, &r); err != nil {
+		return fmt.Errorf("storing dkim rotation state: %v", err)
+	}
+	dkimRotationEvent(ctx, domain, time.Now(), "recordsremoved", sels, "DNS records of old selectors marked as removed.")
+	return nil
+}
+
+// DKIMRotationStatus is the state of automatic DKIM key rotation for a domain.
+type DKIMRotationStatus struct {
+	Config      *config.DKIMRotation // Nil if rotation is not configured.
+	Provisioned bool                 // Whether DNS records are managed through DNS provisioning.
+
+	Rotated  time.Time // Previous rotation completed, or rotation enabled. Zero if rotation has not started.
+	Added    time.Time // New selectors added, zero if no rotation in progress.
+	Switched time.Time // Signing switched to new selectors, zero if not yet.
+	Old      []string  // Selectors being replaced.
+	New      []string  // Selectors added, in order of Old.
+
+	// Next stage of the rotation, "add", "switch" or "remove", and when it is due.
+	// The switch is delayed until the new DNS records are found.
+	NextStage string
+	Next      time.Time
+
+	// DNS records the administrator must add or remove, for domains without DNS
+	// provisioning.
+	Tasks []DKIMRotationTask
+
+	History []store.DKIMRotationEvent // Most recent first.
+}
+
+// DKIMRotationTask is a DNS change the administrator must make for DKIM key
+// rotation.
+type DKIMRotationTask struct {
+	Action string // "add" or "remove".
+	Name   string // E.g. "20240101a._domainkey.example.com".
+	Value  string // TXT record value for action "add".
+}
+
+// DKIMRotationInfo returns the rotation state for a domain, with tasks for the
+// administrator and rotation history.
+func DKIMRotationInfo(ctx context.Context, domain dns.Domain) (DKIMRotationStatus, error) {
+	domConf, ok := mox.Conf.Domain(domain)
+	if !ok {
+		return DKIMRotationStatus{}, fmt.Errorf("%w: domain does not exist", ErrRequest)
+	}
+	st := DKIMRotationStatus{
+		Config:      DKIMRotationConfig(domConf),
+		Provisioned: domConf.DNSProvisioning != nil,
+	}
+
+	q := bstore.QueryDB[store.DKIMRotation](ctx, store.AuthDB)
+	q.FilterNonzero(store.DKIMRotation{Domain: domain.Name()})
+	r, err := q.Get()
+	if err != nil && !errors.Is(err, bstore.ErrAbsent) {
+		return DKIMRotationStatus{}, fmt.Errorf("get dkim rotation state: %v", err)
+	}
+	started := err == nil
+	st.Rotated, st.Added, st.Switched, st.Old, st.New = r.Rotated, r.Added, r.Switched, r.Old, r.New
+
+	if st.Config != nil && started {
+		interval, publishDelay, retainPeriod := DKIMRotationPeriods(st.Config)
+		switch {
+		case r.Added.IsZero():
+			st.NextStage, st.Next = "add", r.Rotated.Add(interval)
+		case r.Switched.IsZero():
+			st.NextStage, st.Next = "switch", r.Added.Add(publishDelay)
+		default:
+			st.NextStage, st.Next = "remove", r.Switched.Add(retainPeriod)
+		}
+	}
+
+	if started && !st.Provisioned {
+		if !r.Added.IsZero() && r.Switched.IsZero() {
+			for _, name := range r.New {
+				sel, ok := domConf.DKIM.Selectors[name]
+				if !ok {
+					continue
+				}
+				txt, err := dkimRecordTXT(name, sel)
+				if err != nil {
+					return DKIMRotationStatus{}, err
+				}
+				st.Tasks = append(st.Tasks, DKIMRotationTask{"add", name + "._domainkey." + domain.ASCII, txt})
+			}
+		}
+		for _, name := range r.RemoveRecords {
+			st.Tasks = append(st.Tasks, DKIMRotationTask{"remove", name + "._domainkey." + domain.ASCII, ""})
+		}
+	}
+
+	eq := bstore.QueryDB[store.DKIMRotationEvent](ctx, store.AuthDB)
+	eq.FilterNonzero(store.DKIMRotationEvent{Domain: domain.Name()})
+	eq.SortDesc("Time")
+	eq.Limit(100)
+	st.History, err = eq.List()
+	if err != nil {
+		return DKIMRotationStatus{}, fmt.Errorf("listing dkim rotation history: %v", err)
+	}
+	return st, nil
+}
+
+// dkimRotateAdd adds a new selector for each old selector, with the same
+// settings and type of key. Selectors are named after the date with a letter
+// suffix, e.g. 20240101a. On error, added selectors are removed again.
+func dkimRotateAdd(ctx context.Context, domain dns.Domain, domConf config.Domain, old []string, now time.Time) (nsels []string, rerr error) {
+	defer func() {
+		if rerr == nil {
+			return
+		}
+		for _, name := range nsels {
+			err := DKIMRemove(ctx, domain, dns.Domain{ASCII: name})
+			pkglog.WithContext(ctx).Check(err, "removing new dkim selector after error", slog.String("selector", name))
+		}
+		nsels = nil
+	}()
+
+	date := now.Format("20060102")
+	suffix := 'a'
+	for _, oname := range old {
+		osel, ok := domConf.DKIM.Selectors[oname]
+		if !ok {
+			return nsels, fmt.Errorf("unknown selector %q used for signing", oname)
+		}
+
+		var name string
+		for ; suffix <= 'z'; suffix++ {
+			name = date + string(suffix)
+			if _, ok := domConf.DKIM.Selectors[name]; !ok {
+				break
+			}
+		}
+		if suffix > 'z' {
+			return nsels, fmt.Errorf("no available name for new selector")
+		}
+		suffix++
+
+		algorithm := "rsa"
+		if osel.Algorithm == "ed25519" {
+			algorithm = "ed25519"
+		}
+		hash := osel.Hash
+		if hash == "" {
+			hash = "sha256"
+		}
+		lifetime := time.Duration(osel.ExpirationSeconds) * time.Second
+		err := DKIMAdd(ctx, domain, dns.Domain{ASCII: name}, algorithm, hash, osel.Canonicalization.HeaderRelaxed, osel.Canonicalization.BodyRelaxed, !osel.DontSealHeaders, osel.Headers, lifetime)
+		if err != nil {
+			return nsels, err
+		}
+		nsels = append(nsels, name)
+	}
+	return nsels, nil
+}
+
+// dkimSignReplace replaces selectors in old with the selector at the same index
+// in new, in Sign and SignRules of the domain.
+func dkimSignReplace(ctx context.Context, domain dns.Domain, old, new []string) error {
+	log := pkglog.WithContext(ctx)
+
+	defer mox.Conf.DynamicLockUnlock()()
+
+	c := mox.Conf.Dynamic
+	d, ok := c.Domains[domain.Name()]
+	if !ok {
+		return fmt.Errorf("%w: domain does not exist", ErrRequest)
+	}
+	for _, name := range new {
+		if _, ok := d.DKIM.Selectors[name]; !ok {
+			return fmt.Errorf("%w: selector %q does not exist", ErrRequest, name)
+		}
+	}
+
+	replace := func(l []string) []string {
+		var nl []string
+		for _, name := range l {
+			if i := slices.Index(old, name); i >= 0 {
+				name = new[i]
+			}
+			nl = append(nl, name)
+		}
+		return nl
+	}
+	nd := d
+	nd.DKIM.Sign = replace(d.DKIM.Sign)
+	nd.DKIM.SignRules = nil
+	for _, rule := range d.DKIM.SignRules {
+		rule.Sign = replace(rule.Sign)
+		nd.DKIM.SignRules = append(nd.DKIM.SignRules, rule)
+	}
+	nc := c
+	nc.Domains = map[string]config.Domain{}
+	for name, dom := range c.Domains {
+		nc.Domains[name] = dom
+	}
+	nc.Domains[domain.Name()] = nd
+
+	if err := mox.WriteDynamicLocked(ctx, log, nc); err != nil {
+		return fmt.Errorf("writing domains.conf: %w", err)
+	}
+	return nil
+}
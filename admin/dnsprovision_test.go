@@ -0,0 +1,285 @@
+package admin
+
+import (
+	"context"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func TestDKIMRotate(t *testing.T) {
+	// Fake deSEC API, with records by subname and type.
+	var zoneLock sync.Mutex
+	zone := map[string][]string{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		zoneLock.Lock()
+		defer zoneLock.Unlock()
+		const prefix = "/domains/mox.example/rrsets/"
+		switch r.Method {
+		case "GET":
+			k := strings.Replace(strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, prefix), "/"), "/", " ", 1)
+			if len(zone[k]) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"records": zone[k]})
+		case "PUT":
+			var l []struct {
+				Subname string
+				Type    string
+				Records []string
+			}
+			err := json.NewDecoder(r.Body).Decode(&l)
+			tcheck(t, err, "parse rrsets")
+			for _, rrset := range l {
+				sub := rrset.Subname
+				if sub == "" {
+					sub = "@"
+				}
+				zone[sub+" "+rrset.Type] = rrset.Records
+			}
+		}
+	}))
+	defer srv.Close()
+	published := func(sub string) bool {
+		zoneLock.Lock()
+		defer zoneLock.Unlock()
+		return len(zone[sub+"._domainkey TXT"]) > 0
+	}
+
+	// Keys are added and removed, so work on a copy of the config.
+	dir := t.TempDir()
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	static, err := os.ReadFile(filepath.FromSlash("../testdata/store/mox.conf"))
+	tcheck(t, err, "read config")
+	static = append(static, []byte("DNSProviders:\n\tdesec:\n\t\tDeSEC:\n\t\t\tAPIToken: test\n\t\t\tBaseURL: "+srv.URL+"\n")...)
+	err = os.WriteFile(mox.ConfigStaticPath, static, 0660)
+	tcheck(t, err, "write config")
+	dynamic, err := os.ReadFile(filepath.FromSlash("../testdata/store/domains.conf"))
+	tcheck(t, err, "read config")
+	dynamic = []byte(strings.Replace(string(dynamic), "\tmox.example: nil\n", "\tmox.example:\n\t\tDNSProvisioning:\n\t\t\tProvider: desec\n\t\t\tDKIMRotation:\n\t\t\t\tInterval: 24h\n\t\t\t\tPublishDelay: 2h\n\t\t\t\tRetainPeriod: 2h\n", 1))
+	err = os.WriteFile(mox.ConfigDynamicPath, dynamic, 0660)
+	tcheck(t, err, "write config")
+	mox.MustLoadConfig(true, false)
+	err = store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	err = DKIMAdd(ctxbg, domain, dns.Domain{ASCII: "sel1"}, "ed25519", "sha256", true, true, true, nil, 72*time.Hour)
+	tcheck(t, err, "add dkim selector")
+	func() {
+		defer mox.Conf.DynamicLockUnlock()()
+		nc := mox.Conf.Dynamic
+		nc.Domains = maps.Clone(nc.Domains)
+		d := nc.Domains["mox.example"]
+		d.DKIM.Sign = []string{"sel1"}
+		nc.Domains["mox.example"] = d
+		err := mox.WriteDynamicLocked(ctxbg, pkglog, nc)
+		tcheck(t, err, "write config")
+	}()
+
+	changed, err := DNSProvision(ctxbg, domain)
+	tcheck(t, err, "provision dns")
+	if !slices.Equal(changed, []string{"TXT sel1._domainkey.mox.example", "TXT mox.example"}) {
+		t.Fatalf("changed records %v", changed)
+	}
+	changed, err = DNSProvision(ctxbg, domain)
+	tcheck(t, err, "provision dns")
+	if len(changed) != 0 {
+		t.Fatalf("changed records %v, expected none", changed)
+	}
+
+	dkim := func() ([]string, []string) {
+		t.Helper()
+		dc, ok := mox.Conf.Domain(domain)
+		if !ok {
+			t.Fatalf("domain not found")
+		}
+		return slices.Sorted(maps.Keys(dc.DKIM.Selectors)), dc.DKIM.Sign
+	}
+
+	now := time.Now()
+	rotate := func(d time.Duration) {
+		t.Helper()
+		err := DKIMRotate(ctxbg, dns.MockResolver{}, domain, now.Add(d))
+		tcheck(t, err, "rotate dkim")
+	}
+
+	// First call starts the interval, nothing happens until interval has passed.
+	rotate(0)
+	rotate(23 * time.Hour)
+	sels, sign := dkim()
+	if len(sels) != 1 {
+		t.Fatalf("selectors %v, expected 1", sels)
+	}
+
+	// New selector is added and published, still signing with old selector.
+	rotate(25 * time.Hour)
+	nsel := now.Add(25*time.Hour).Format("20060102") + "a"
+	sels, sign = dkim()
+	if !slices.Equal(sels, []string{nsel, "sel1"}) || !slices.Equal(sign, []string{"sel1"}) {
+		t.Fatalf("selectors %v, sign %v", sels, sign)
+	}
+	if !published(nsel) {
+		t.Fatalf("new selector not published")
+	}
+
+	// Switch to new selector after publish delay.
+	rotate(26 * time.Hour)
+	_, sign = dkim()
+	if !slices.Equal(sign, []string{"sel1"}) {
+		t.Fatalf("sign %v before publish delay", sign)
+	}
+	rotate(28 * time.Hour)
+	_, sign = dkim()
+	if !slices.Equal(sign, []string{nsel}) {
+		t.Fatalf("sign %v, expected new selector", sign)
+	}
+
+	// Old selector is removed after retain period.
+	rotate(31 * time.Hour)
+	sels, _ = dkim()
+	if !slices.Equal(sels, []string{nsel}) {
+		t.Fatalf("selectors %v after rotation", sels)
+	}
+	if published("sel1") || !published(nsel) {
+		t.Fatalf("old selector still published, or new selector not published")
+	}
+
+	// Next rotation starts an interval later.
+	rotate(50 * time.Hour)
+	sels, _ = dkim()
+	if len(sels) != 1 {
+		t.Fatalf("selectors %v, expected no new rotation yet", sels)
+	}
+}
+
+func TestDKIMRotateManual(t *testing.T) {
+	// Without dns provisioning, the administrator adds and removes dns records.
+	dir := t.TempDir()
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	static, err := os.ReadFile(filepath.FromSlash("../testdata/store/mox.conf"))
+	tcheck(t, err, "read config")
+	err = os.WriteFile(mox.ConfigStaticPath, static, 0660)
+	tcheck(t, err, "write config")
+	dynamic, err := os.ReadFile(filepath.FromSlash("../testdata/store/domains.conf"))
+	tcheck(t, err, "read config")
+	err = os.WriteFile(mox.ConfigDynamicPath, dynamic, 0660)
+	tcheck(t, err, "write config")
+	mox.MustLoadConfig(true, false)
+	err = store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	domain := dns.Domain{ASCII: "mox.example"}
+	err = DKIMAdd(ctxbg, domain, dns.Domain{ASCII: "sel1"}, "ed25519", "sha256", true, true, true, nil, 72*time.Hour)
+	tcheck(t, err, "add dkim selector")
+	func() {
+		defer mox.Conf.DynamicLockUnlock()()
+		nc := mox.Conf.Dynamic
+		nc.Domains = maps.Clone(nc.Domains)
+		d := nc.Domains["mox.example"]
+		d.DKIM.Sign = []string{"sel1"}
+		d.DKIM.Rotation = &config.DKIMRotation{Interval: 24 * time.Hour, PublishDelay: 2 * time.Hour, RetainPeriod: 2 * time.Hour}
+		nc.Domains["mox.example"] = d
+		err := mox.WriteDynamicLocked(ctxbg, pkglog, nc)
+		tcheck(t, err, "write config")
+	}()
+
+	resolver := dns.MockResolver{TXT: map[string][]string{}}
+	now := time.Now()
+	rotate := func(d time.Duration) {
+		t.Helper()
+		err := DKIMRotate(ctxbg, resolver, domain, now.Add(d))
+		tcheck(t, err, "rotate dkim")
+	}
+	sign := func() []string {
+		t.Helper()
+		dc, ok := mox.Conf.Domain(domain)
+		if !ok {
+			t.Fatalf("domain not found")
+		}
+		return dc.DKIM.Sign
+	}
+
+	rotate(0)
+	rotate(25 * time.Hour)
+	nsel := now.Add(25*time.Hour).Format("20060102") + "a"
+
+	// Not switching while the record is not in DNS.
+	rotate(28 * time.Hour)
+	if !slices.Equal(sign(), []string{"sel1"}) {
+		t.Fatalf("sign %v, expected old selector while new record is not in dns", sign())
+	}
+
+	// Record with wrong key is not good enough.
+	dc, _ := mox.Conf.Domain(domain)
+	oldtxt, err := dkimRecordTXT("sel1", dc.DKIM.Selectors["sel1"])
+	tcheck(t, err, "dkim record")
+	resolver.TXT[nsel+"._domainkey.mox.example."] = []string{oldtxt}
+	rotate(29 * time.Hour)
+	if !slices.Equal(sign(), []string{"sel1"}) {
+		t.Fatalf("sign %v, expected old selector with wrong key in dns", sign())
+	}
+
+	txt, err := dkimRecordTXT(nsel, dc.DKIM.Selectors[nsel])
+	tcheck(t, err, "dkim record")
+	resolver.TXT[nsel+"._domainkey.mox.example."] = []string{txt}
+	rotate(30 * time.Hour)
+	if !slices.Equal(sign(), []string{nsel}) {
+		t.Fatalf("sign %v, expected new selector", sign())
+	}
+
+	// Old selector removed, administrator must remove the dns record.
+	rotate(33 * time.Hour)
+	r, err := bstore.QueryDB[store.DKIMRotation](ctxbg, store.AuthDB).FilterNonzero(store.DKIMRotation{Domain: "mox.example"}).Get()
+	tcheck(t, err, "get rotation state")
+	if !slices.Equal(r.RemoveRecords, []string{"sel1"}) {
+		t.Fatalf("remove records %v, expected old selector", r.RemoveRecords)
+	}
+	err = DKIMRotationRecordsRemoved(ctxbg, domain)
+	tcheck(t, err, "records removed")
+
+	events, err := bstore.QueryDB[store.DKIMRotationEvent](ctxbg, store.AuthDB).SortAsc("ID").List()
+	tcheck(t, err, "list events")
+	var kinds []string
+	for _, e := range events {
+		kinds = append(kinds, e.Kind)
+	}
+	if !slices.Equal(kinds, []string{"started", "added", "switched", "removed", "recordsremoved"}) {
+		t.Fatalf("events %v", kinds)
+	}
+}
@@ -4,14 +4,13 @@ import (
 	"crypto"
 	"crypto/ed25519"
 	"crypto/rsa"
-	"crypto/sha256"
-	"crypto/x509"
 	"fmt"
 	"net/url"
 	"strings"
 
 	"github.com/mjl-/adns"
 
+	"github.com/mjl-/mox/bimi"
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dmarc"
@@ -23,7 +22,8 @@ import (
 	"slices"
 )
 
-// todo: find a way to automatically create the dns records as it would greatly simplify setting up email for a domain. we could also dynamically make changes, e.g. providing grace periods after disabling a dkim key, only automatically removing the dkim dns key after a few days. but this requires some kind of api and authentication to the dns server. there doesn't appear to be a single commonly used api for dns management. each of the numerous cloud providers have their own APIs and rather large SKDs to use them. we don't want to link all of them in.
+// Records can also be created and kept up to date automatically for domains with
+// DNS provisioning through a DNS provider, see DNSProvision.
 
 // DomainRecords returns text lines describing DNS records required for configuring
 // a domain.
@@ -64,18 +64,11 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 				"; commented out.",
 			)
 		}
-		addTLSA := func(privKey crypto.Signer) error {
-			spkiBuf, err := x509.MarshalPKIXPublicKey(privKey.Public())
-			if err != nil {
-				return fmt.Errorf("marshal SubjectPublicKeyInfo for DANE record: %v", err)
-			}
-			sum := sha256.Sum256(spkiBuf)
-			tlsaRecord := adns.TLSA{
-				Usage:     adns.TLSAUsageDANEEE,
-				Selector:  adns.TLSASelectorSPKI,
-				MatchType: adns.TLSAMatchTypeSHA256,
-				CertAssoc: sum[:],
-			}
+		tlsaRecords, err := hostTLSARecords(public)
+		if err != nil {
+			return nil, err
+		}
+		for _, tlsaRecord := range tlsaRecords {
 			var s string
 			if hasDNSSEC {
 				s = fmt.Sprintf("_25._tcp.%-*s TLSA %s", 20+len(d)-len("_25._tcp."), h+".", tlsaRecord.Record())
@@ -83,17 +76,6 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 				s = fmt.Sprintf(";; _25._tcp.%-*s TLSA %s", 20+len(d)-len(";; _25._tcp."), h+".", tlsaRecord.Record())
 			}
 			records = append(records, s)
-			return nil
-		}
-		for _, privKey := range public.TLS.HostPrivateECDSAP256Keys {
-			if err := addTLSA(privKey); err != nil {
-				return nil, err
-			}
-		}
-		for _, privKey := range public.TLS.HostPrivateRSA2048Keys {
-			if err := addTLSA(privKey); err != nil {
-				return nil, err
-			}
 		}
 		records = append(records, "")
 	}
@@ -137,20 +119,9 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 	}
 	slices.Sort(selectors)
 	for _, name := range selectors {
-		sel := domConf.DKIM.Selectors[name]
-		dkimr := dkim.Record{
-			Version:   "DKIM1",
-			Hashes:    []string{"sha256"},
-			PublicKey: sel.Key.Public(),
-		}
-		if _, ok := sel.Key.(ed25519.PrivateKey); ok {
-			dkimr.Key = "ed25519"
-		} else if _, ok := sel.Key.(*rsa.PrivateKey); !ok {
-			return nil, fmt.Errorf("unrecognized private key for DKIM selector %q: %T", name, sel.Key)
-		}
-		txt, err := dkimr.Record()
+		txt, err := dkimRecordTXT(name, domConf.DKIM.Selectors[name])
 		if err != nil {
-			return nil, fmt.Errorf("making DKIM DNS TXT record: %v", err)
+			return nil, err
 		}
 
 		if len(txt) > 100 {
@@ -175,21 +146,9 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 			{Address: uri.String(), MaxSize: 10, Unit: "m"},
 		}
 	}
-	dspfr := spf.Record{Version: "spf1"}
-	for _, ip := range mox.DomainSPFIPs() {
-		mech := "ip4"
-		if ip.To4() == nil {
-			mech = "ip6"
-		}
-		dspfr.Directives = append(dspfr.Directives, spf.Directive{Mechanism: mech, IP: ip})
-	}
-	dspfr.Directives = append(dspfr.Directives,
-		spf.Directive{Mechanism: "mx"},
-		spf.Directive{Qualifier: "~", Mechanism: "all"},
-	)
-	dspftxt, err := dspfr.Record()
+	dspftxt, err := domainSPFTXT()
 	if err != nil {
-		return nil, fmt.Errorf("making domain spf record: %v", err)
+		return nil, err
 	}
 	records = append(records,
 		"",
@@ -238,6 +197,30 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 		)
 	}
 
+	for _, l := range mox.Conf.Static.Listeners {
+		if l.WKDHTTPS.Enabled {
+			records = append(records,
+				"; Email applications can look up OpenPGP keys published by accounts in the Web",
+				"; Key Directory.",
+				fmt.Sprintf(`openpgpkey.%s.         CNAME %s.`, d, h),
+				"",
+			)
+			break
+		}
+	}
+
+	if b := domConf.BIMI; b != nil {
+		records = append(records,
+			"; Location of the logo shown by mail clients of recipients for messages passing",
+			"; DMARC. Requires a DMARC policy of quarantine (for all messages) or reject.",
+			fmt.Sprintf(`%s._bimi.%s.         TXT "%s"`, b.SelectorEffective, d, bimiRecord(*b, domain).String()),
+		)
+		if b.LogoFile != "" || b.AuthorityFile != "" {
+			records = append(records, fmt.Sprintf(`bimi.%s.         CNAME %s.`, d, h))
+		}
+		records = append(records, "")
+	}
+
 	if domConf.ClientSettingsDomain != "" && domConf.ClientSettingsDNSDomain != mox.Conf.Static.HostnameDomain {
 		records = append(records,
 			"; Client settings will reference a subdomain of the hosted domain, making it",
@@ -291,6 +274,18 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 				fmt.Sprintf(`;; autoconfig.%s.      CAA 0 issue "%s; accounturi=%s; validationmethods=tls-alpn-01,http-01"`, d, certIssuerDomainName, acmeAccountURI),
 				fmt.Sprintf(`;; mta-sts.%s.         CAA 0 issue "%s; accounturi=%s; validationmethods=tls-alpn-01,http-01"`, d, certIssuerDomainName, acmeAccountURI),
 			)
+			if b := domConf.BIMI; b != nil {
+				records = append(records,
+					"; Location of the logo shown by mail clients of recipients for messages passing",
+					"; DMARC. Requires a DMARC policy of quarantine (for all messages) or reject.",
+					fmt.Sprintf(`%s._bimi.%s.         TXT "%s"`, b.SelectorEffective, d, bimiRecord(*b, domain).String()),
+				)
+				if b.LogoFile != "" || b.AuthorityFile != "" {
+					records = append(records, fmt.Sprintf(`bimi.%s.         CNAME %s.`, d, h))
+				}
+				records = append(records, "")
+			}
+
 			if domConf.ClientSettingsDomain != "" && domConf.ClientSettingsDNSDomain != mox.Conf.Static.HostnameDomain {
 				records = append(records,
 					fmt.Sprintf(`;; %-*s CAA 0 issue "%s; accounturi=%s; validationmethods=tls-alpn-01,http-01"`, 20-3+len(d), domConf.ClientSettingsDNSDomain.ASCII, certIssuerDomainName, acmeAccountURI),
@@ -316,3 +311,83 @@ func DomainRecords(domConf config.Domain, domain dns.Domain, hasDNSSEC bool, cer
 	}
 	return records, nil
 }
+
+// hostTLSARecords returns DANE-EE TLSA records for the host private keys of the
+// listener.
+func hostTLSARecords(l config.Listener) ([]adns.TLSA, error) {
+	var records []adns.TLSA
+	addTLSA := func(privKey crypto.Signer) error {
+		r, err := mox.DANERecord(privKey.Public())
+		if err != nil {
+			return err
+		}
+		records = append(records, r)
+		return nil
+	}
+	for _, privKey := range l.TLS.HostPrivateECDSAP256Keys {
+		if err := addTLSA(privKey); err != nil {
+			return nil, err
+		}
+	}
+	for _, privKey := range l.TLS.HostPrivateRSA2048Keys {
+		if err := addTLSA(privKey); err != nil {
+			return nil, err
+		}
+	}
+	return records, nil
+}
+
+// bimiRecord returns the BIMI DNS record for the logo and authority of a domain,
+// served by the BIMIHTTPS listener or hosted elsewhere.
+func bimiRecord(b config.BIMI, domain dns.Domain) bimi.Record {
+	r := bimi.Record{Version: "BIMI1", Location: b.LogoURL, Authority: b.AuthorityURL}
+	base := "https://bimi." + domain.ASCII + "/.well-known/bimi/" + b.SelectorEffective
+	if b.LogoFile != "" {
+		r.Location = base + ".svg"
+	}
+	if b.AuthorityFile != "" {
+		r.Authority = base + ".pem"
+	}
+	return r
+}
+
+// dkimRecordTXT returns the DNS TXT record for a DKIM selector.
+func dkimRecordTXT(name string, sel config.Selector) (string, error) {
+	dkimr := dkim.Record{
+		Version:   "DKIM1",
+		Hashes:    []string{"sha256"},
+		PublicKey: sel.Key.Public(),
+	}
+	if _, ok := sel.Key.(ed25519.PrivateKey); ok {
+		dkimr.Key = "ed25519"
+	} else if _, ok := sel.Key.(*rsa.PrivateKey); !ok {
+		return "", fmt.Errorf("unrecognized private key for DKIM selector %q: %T", name, sel.Key)
+	}
+	txt, err := dkimr.Record()
+	if err != nil {
+		return "", fmt.Errorf("making DKIM DNS TXT record: %v", err)
+	}
+	return txt, nil
+}
+
+// domainSPFTXT returns the SPF record for hosted domains, allowing the IPs of
+// this machine and the MX host.
+func domainSPFTXT() (string, error) {
+	dspfr := spf.Record{Version: "spf1"}
+	for _, ip := range mox.DomainSPFIPs() {
+		mech := "ip4"
+		if ip.To4() == nil {
+			mech = "ip6"
+		}
+		dspfr.Directives = append(dspfr.Directives, spf.Directive{Mechanism: mech, IP: ip})
+	}
+	dspfr.Directives = append(dspfr.Directives,
+		spf.Directive{Mechanism: "mx"},
+		spf.Directive{Qualifier: "~", Mechanism: "all"},
+	)
+	txt, err := dspfr.Record()
+	if err != nil {
+		return "", fmt.Errorf("making domain spf record: %v", err)
+	}
+	return txt, nil
+}
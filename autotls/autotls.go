@@ -2,9 +2,9 @@
 // requesting certificates with ACME, typically from Let's Encrypt.
 package autotls
 
-// We do tls-alpn-01, and also http-01. For DNS we would need a third party tool
-// with an API that can make the DNS changes, as we don't want to link in dozens of
-// bespoke API's for DNS record manipulation into mox.
+// We do tls-alpn-01, and also http-01. DNS-01 is done when configured with a
+// function to set TXT records, typically through one of the DNS providers in
+// package dnsprovision. DNS-01 is also needed for wildcard certificates.
 
 import (
 	"bytes"
@@ -77,7 +77,9 @@ type Manager struct {
 	shutdown <-chan struct{}
 
 	sync.Mutex
-	hosts map[dns.Domain]struct{}
+	hosts      map[dns.Domain]struct{}
+	dns01      *DNS01
+	dns01Certs map[string]*dns01Cert // By cache key, e.g. "*.example.com" or "mail.example.com+rsa".
 }
 
 // Load returns an initialized autotls manager for "name" (used for the ACME key
@@ -179,9 +181,10 @@ func Load(log mlog.Log, name, acmeDir, contactEmail, directoryURL string, eabKey
 	}
 
 	a := &Manager{
-		Manager:  m,
-		shutdown: shutdown,
-		hosts:    map[dns.Domain]struct{}{},
+		Manager:    m,
+		shutdown:   shutdown,
+		hosts:      map[dns.Domain]struct{}{},
+		dns01Certs: map[string]*dns01Cert{},
 	}
 	m.HostPolicy = a.HostPolicy
 	acmeTLSConfig := *m.TLSConfig()
@@ -234,7 +237,7 @@ func (m *Manager) loggingGetCertificate(hello *tls.ClientHelloInfo, fallbackHost
 		return nil, nil
 	}
 
-	cert, err := m.Manager.GetCertificate(hello)
+	cert, err := m.GetCertificate(hello)
 	if err != nil && errors.Is(err, errHostNotAllowed) {
 		if !fallbackUnknownSNI {
 			metricUnknownServerName.Inc()
@@ -250,7 +253,7 @@ func (m *Manager) loggingGetCertificate(hello *tls.ClientHelloInfo, fallbackHost
 		log = log.With(slog.String("servername", hello.ServerName))
 		log.Debug("certificate for unknown hostname, using fallback hostname")
 		hello.ServerName = fallbackHostname.ASCII
-		cert, err = m.Manager.GetCertificate(hello)
+		cert, err = m.GetCertificate(hello)
 		if err != nil {
 			metricCertRequestErrors.Inc()
 			log.Errorx("requesting certificate for fallback hostname", err)
@@ -284,37 +287,74 @@ func (m *Manager) TLSConfig(fallbackHostname dns.Domain, fallbackNoSNI, fallback
 }
 
 // CertAvailable checks whether a non-expired ECDSA certificate is available in the
-// cache for host. No other checks than expiration are done.
+// cache for host, or for its wildcard name when configured. No other checks than
+// expiration are done.
 func (m *Manager) CertAvailable(ctx context.Context, log mlog.Log, host dns.Domain) (bool, error) {
-	ck := host.ASCII // Would be "+rsa" for rsa keys.
+	_, cert, err := m.CachedKeyCert(ctx, host, false)
+	if err != nil || cert == nil {
+		return false, err
+	}
+	// We assume the certificate has a matching hostname, and is properly CA-signed. We
+	// only check the expiration time.
+	if time.Until(cert.NotBefore) > 0 || time.Since(cert.NotAfter) > 0 {
+		return false, nil
+	}
+	return true, nil
+}
+
+// CachedKeyCert returns the private key and leaf certificate from the cache for
+// host, or for its wildcard name when configured. For an ECDSA certificate, or for
+// an RSA certificate if isRSA is set. If no certificate is in the cache, a nil key
+// and certificate are returned without error. The certificate may be expired.
+func (m *Manager) CachedKeyCert(ctx context.Context, host dns.Domain, isRSA bool) (crypto.Signer, *x509.Certificate, error) {
+	ck := m.certName(host)
+	if isRSA {
+		ck += "+rsa"
+	}
 	data, err := m.Manager.Cache.Get(ctx, ck)
 	if err != nil && errors.Is(err, autocert.ErrCacheMiss) {
-		return false, nil
+		return nil, nil, nil
 	} else if err != nil {
-		return false, fmt.Errorf("attempt to get certificate from cache: %v", err)
+		return nil, nil, fmt.Errorf("attempt to get certificate from cache: %v", err)
 	}
 
 	// The cached keycert is of the form: private key, leaf certificate, intermediate certificates...
 	privb, rem := pem.Decode(data)
 	if privb == nil {
-		return false, fmt.Errorf("missing private key in cached keycert file")
+		return nil, nil, fmt.Errorf("missing private key in cached keycert file")
+	}
+	var key crypto.Signer
+	switch privb.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(privb.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(privb.Bytes)
+	case "PRIVATE KEY":
+		var k any
+		k, err = x509.ParsePKCS8PrivateKey(privb.Bytes)
+		if err == nil {
+			var ok bool
+			if key, ok = k.(crypto.Signer); !ok {
+				err = fmt.Errorf("unsupported private key type %T", k)
+			}
+		}
+	default:
+		err = fmt.Errorf("unrecognized pem block %q", privb.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing private key from cached keycert file: %v", err)
 	}
 	pubb, _ := pem.Decode(rem)
 	if pubb == nil {
-		return false, fmt.Errorf("missing certificate in cached keycert file")
+		return nil, nil, fmt.Errorf("missing certificate in cached keycert file")
 	} else if pubb.Type != "CERTIFICATE" {
-		return false, fmt.Errorf("second pem block is %q, expected CERTIFICATE", pubb.Type)
+		return nil, nil, fmt.Errorf("second pem block is %q, expected CERTIFICATE", pubb.Type)
 	}
 	cert, err := x509.ParseCertificate(pubb.Bytes)
 	if err != nil {
-		return false, fmt.Errorf("parsing certificate from cached keycert file: %v", err)
+		return nil, nil, fmt.Errorf("parsing certificate from cached keycert file: %v", err)
 	}
-	// We assume the certificate has a matching hostname, and is properly CA-signed. We
-	// only check the expiration time.
-	if time.Until(cert.NotBefore) > 0 || time.Since(cert.NotAfter) > 0 {
-		return false, nil
-	}
-	return true, nil
+	return key, cert, nil
 }
 
 // SetAllowedHostnames sets a new list of allowed hostnames for automatic TLS.
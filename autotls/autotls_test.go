@@ -3,11 +3,29 @@ package autotls
 import (
 	"context"
 	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"golang.org/x/crypto/acme"
 
 	"github.com/mjl-/autocert"
 
@@ -106,3 +124,237 @@ func TestAutotls(t *testing.T) {
 	// Only remove in case of success.
 	os.RemoveAll("../testdata/autotls")
 }
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func TestDNS01(t *testing.T) {
+	log := mlog.New("autotls", nil)
+
+	// CA for issuing certificates from the fake ACME server.
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	tcheck(t, err, "generate ca key")
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(cryptorand.Reader, caTmpl, caTmpl, caKey.Public(), caKey)
+	tcheck(t, err, "create ca certificate")
+
+	var m *Manager
+
+	// TXT records, set through the DNS01 config below.
+	var lock sync.Mutex
+	txt := map[string][]string{}
+
+	// State of orders, by order number.
+	var orders []string // Identifier, e.g. "*.mox.example".
+	valid := map[int]bool{}
+	csrKeys := map[int]any{}
+
+	// Fake ACME server, only handling the requests for orders with dns-01 challenges.
+	// Signatures of requests are not verified.
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		w.Header().Set("Replay-Nonce", "nonce")
+		var payload []byte
+		if r.Method == "POST" {
+			var jws struct{ Payload string }
+			err := json.NewDecoder(r.Body).Decode(&jws)
+			tcheck(t, err, "parse jws")
+			payload, err = base64.RawURLEncoding.DecodeString(jws.Payload)
+			tcheck(t, err, "decode jws payload")
+		}
+
+		path, id, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		var n int
+		if id != "" {
+			n, err = strconv.Atoi(id)
+			tcheck(t, err, "parse id")
+		}
+		url := func(kind string) string {
+			return fmt.Sprintf("%s/%s/%d", srv.URL, kind, n)
+		}
+		var v any
+		switch path {
+		case "dir":
+			v = map[string]string{
+				"newNonce":   srv.URL + "/nonce",
+				"newAccount": srv.URL + "/account",
+				"newOrder":   srv.URL + "/neworder",
+			}
+		case "nonce":
+			return
+		case "account":
+			w.Header().Set("Location", srv.URL+"/account/1")
+			v = map[string]string{"status": "valid"}
+		case "neworder":
+			var req struct{ Identifiers []acme.AuthzID }
+			err := json.Unmarshal(payload, &req)
+			tcheck(t, err, "parse order request")
+			orders = append(orders, req.Identifiers[0].Value)
+			n = len(orders) - 1
+			w.Header().Set("Location", url("order"))
+			w.WriteHeader(http.StatusCreated)
+			v = map[string]any{
+				"status":         "pending",
+				"identifiers":    req.Identifiers,
+				"authorizations": []string{url("authz")},
+				"finalize":       url("finalize"),
+			}
+		case "authz", "challenge":
+			value, wildcard := strings.CutPrefix(orders[n], "*.")
+			if path == "challenge" {
+				expected, err := m.Manager.Client.DNS01ChallengeRecord("token")
+				tcheck(t, err, "challenge record")
+				if l := txt["_acme-challenge."+value]; len(l) != 1 || l[0] != expected {
+					t.Errorf("txt record %v, expected %q", l, expected)
+				} else {
+					valid[n] = true
+				}
+			}
+			status := "pending"
+			if valid[n] {
+				status = "valid"
+			}
+			chal := map[string]string{"type": "dns-01", "url": url("challenge"), "token": "token", "status": status}
+			if path == "challenge" {
+				v = chal
+			} else {
+				v = map[string]any{
+					"status":     status,
+					"identifier": map[string]string{"type": "dns", "value": value},
+					"wildcard":   wildcard,
+					"challenges": []any{chal},
+				}
+			}
+		case "order":
+			w.Header().Set("Location", url("order"))
+			v = map[string]any{"status": "ready", "finalize": url("finalize")}
+		case "finalize":
+			var req struct{ CSR string }
+			err := json.Unmarshal(payload, &req)
+			tcheck(t, err, "parse finalize request")
+			buf, err := base64.RawURLEncoding.DecodeString(req.CSR)
+			tcheck(t, err, "decode csr")
+			csr, err := x509.ParseCertificateRequest(buf)
+			tcheck(t, err, "parse csr")
+			if !reflect.DeepEqual(csr.DNSNames, []string{orders[n]}) {
+				t.Errorf("csr for names %v, expected %s", csr.DNSNames, orders[n])
+			}
+			csrKeys[n] = csr.PublicKey
+			w.Header().Set("Location", url("order"))
+			v = map[string]any{"status": "valid", "certificate": url("cert")}
+		case "cert":
+			tmpl := &x509.Certificate{
+				SerialNumber: big.NewInt(int64(2 + n)),
+				DNSNames:     []string{orders[n]},
+				NotBefore:    time.Now().Add(-time.Hour),
+				NotAfter:     time.Now().Add(90 * 24 * time.Hour),
+			}
+			der, err := x509.CreateCertificate(cryptorand.Reader, tmpl, caTmpl, csrKeys[n], caKey)
+			tcheck(t, err, "create certificate")
+			w.Header().Set("Content-Type", "application/pem-certificate-chain")
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+			pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: caDER})
+			return
+		default:
+			t.Errorf("unexpected acme request %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}))
+	defer srv.Close()
+
+	getPrivateKey := func(host string, keyType autocert.KeyType) (crypto.Signer, error) {
+		return ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	}
+	shutdown := make(chan struct{})
+	defer close(shutdown)
+	m, err = Load(log, "test", t.TempDir(), "mox@localhost", srv.URL+"/dir", "", nil, getPrivateKey, shutdown)
+	tcheck(t, err, "load manager")
+	hosts := map[dns.Domain]struct{}{
+		{ASCII: "mail.mox.example"}:    {},
+		{ASCII: "mta-sts.mox.example"}: {},
+		{ASCII: "mox2.example"}:        {},
+	}
+	m.SetAllowedHostnames(log, dns.MockResolver{}, hosts, nil, false)
+	m.SetDNS01(&DNS01{
+		SetTXT: func(ctx context.Context, name string, values []string) error {
+			lock.Lock()
+			defer lock.Unlock()
+			txt[name] = values
+			return nil
+		},
+		Wildcards: []dns.Domain{{ASCII: "mox.example"}},
+	})
+
+	getCert := func(host string, expName string) *tls.Certificate {
+		t.Helper()
+		hello := &tls.ClientHelloInfo{
+			ServerName:       host,
+			CipherSuites:     []uint16{tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			SupportedCurves:  []tls.CurveID{tls.CurveP256},
+			SignatureSchemes: []tls.SignatureScheme{tls.ECDSAWithP256AndSHA256},
+		}
+		cert, err := m.GetCertificate(hello)
+		tcheck(t, err, "get certificate")
+		if !reflect.DeepEqual(cert.Leaf.DNSNames, []string{expName}) {
+			t.Fatalf("certificate for %v, expected %s", cert.Leaf.DNSNames, expName)
+		}
+		return cert
+	}
+
+	// Hosts under a wildcard domain share a certificate.
+	cert0 := getCert("mail.mox.example", "*.mox.example")
+	cert1 := getCert("mta-sts.mox.example", "*.mox.example")
+	if cert0 != cert1 {
+		t.Fatalf("different certificates for hosts under wildcard domain")
+	}
+	getCert("mox2.example", "mox2.example")
+	if !reflect.DeepEqual(orders, []string{"*.mox.example", "mox2.example"}) {
+		t.Fatalf("orders %v", orders)
+	}
+	for name, values := range txt {
+		if len(values) != 0 {
+			t.Fatalf("txt record %s not removed", name)
+		}
+	}
+
+	if _, err := m.GetCertificate(&tls.ClientHelloInfo{ServerName: "other.mox.example"}); err == nil || !errors.Is(err, errHostNotAllowed) {
+		t.Fatalf("get certificate for unknown host, got err %v, expected errHostNotAllowed", err)
+	}
+
+	if avail, err := m.CertAvailable(context.Background(), log, dns.Domain{ASCII: "mta-sts.mox.example"}); err != nil || !avail {
+		t.Fatalf("cert available, got %v, err %v, expected true", avail, err)
+	}
+	key, leaf, err := m.CachedKeyCert(context.Background(), dns.Domain{ASCII: "mail.mox.example"}, false)
+	tcheck(t, err, "cached keycert")
+	if key == nil || leaf == nil || !key.Public().(*ecdsa.PublicKey).Equal(cert0.Leaf.PublicKey) {
+		t.Fatalf("cached keycert does not match certificate")
+	}
+	if key, leaf, err := m.CachedKeyCert(context.Background(), dns.Domain{ASCII: "mail.mox.example"}, true); err != nil || key != nil || leaf != nil {
+		t.Fatalf("cached rsa keycert, got %v %v %v, expected none", key, leaf, err)
+	}
+
+	// After a restart, the certificates are loaded from the cache.
+	m.dns01Certs = map[string]*dns01Cert{}
+	getCert("mail.mox.example", "*.mox.example")
+	if len(orders) != 2 {
+		t.Fatalf("new order for cached certificate")
+	}
+}
@@ -0,0 +1,398 @@
+package autotls
+
+import (
+	"bytes"
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+
+	"github.com/mjl-/autocert"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+)
+
+// DNS01 configures requesting certificates with DNS-01 challenges.
+type DNS01 struct {
+	// SetTXT replaces the TXT records at name, a fully qualified domain name in ASCII
+	// without trailing dot, with values. Empty values removes the records.
+	SetTXT func(ctx context.Context, name string, values []string) error
+
+	// Time to wait after setting a TXT record before validation is requested.
+	PropagationDelay time.Duration
+
+	// Domains for which a wildcard certificate is requested, used for all allowlisted
+	// hosts directly under the domain.
+	Wildcards []dns.Domain
+}
+
+// dns01Cert is a certificate requested with DNS-01, kept in memory after loading
+// it from the cache or requesting it.
+type dns01Cert struct {
+	sync.Mutex // Held while loading or requesting the certificate.
+	cert       *tls.Certificate
+	renew      *time.Timer
+}
+
+// Renew certificates this long before they expire, or at 2/3 of their lifetime
+// for short-lived certificates.
+const dns01RenewBefore = 30 * 24 * time.Hour
+
+// SetDNS01 makes the manager request certificates with DNS-01 challenges through
+// d, instead of with tls-alpn-01 or http-01. If d is nil, DNS-01 is not used.
+func (m *Manager) SetDNS01(d *DNS01) {
+	m.Lock()
+	defer m.Unlock()
+	m.dns01 = d
+}
+
+// certName returns the name of the certificate to use for host: the host itself,
+// or the wildcard name if a wildcard certificate is configured for its parent
+// domain.
+func (m *Manager) certName(host dns.Domain) string {
+	m.Lock()
+	defer m.Unlock()
+	if m.dns01 != nil {
+		_, parent, ok := strings.Cut(host.ASCII, ".")
+		if ok && slices.ContainsFunc(m.dns01.Wildcards, func(d dns.Domain) bool { return d.ASCII == parent }) {
+			return "*." + parent
+		}
+	}
+	return host.ASCII
+}
+
+// GetCertificate returns a certificate for the SNI server name of hello,
+// requesting one if needed. Certificates are requested with DNS-01 if configured,
+// otherwise through the autocert manager.
+func (m *Manager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	m.Lock()
+	d := m.dns01
+	m.Unlock()
+	if d == nil || slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+		return m.Manager.GetCertificate(hello)
+	}
+
+	name := strings.TrimSuffix(hello.ServerName, ".")
+	if err := m.HostPolicy(context.Background(), name); err != nil {
+		return nil, err
+	}
+	host, err := dns.ParseDomain(name)
+	if err != nil {
+		return nil, fmt.Errorf("parsing server name: %v", err)
+	}
+
+	certName := m.certName(host)
+	ck := certName
+	keyType := autocert.KeyECDSAP256
+	if !supportsECDSA(hello) {
+		ck += "+rsa"
+		keyType = autocert.KeyRSA2048
+	}
+
+	m.Lock()
+	c := m.dns01Certs[ck]
+	if c == nil {
+		c = &dns01Cert{}
+		m.dns01Certs[ck] = c
+	}
+	m.Unlock()
+
+	c.Lock()
+	defer c.Unlock()
+	if c.cert != nil && time.Now().Before(c.cert.Leaf.NotAfter) {
+		return c.cert, nil
+	}
+
+	log := mlog.New("autotls", nil).With(slog.String("certname", certName))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute+d.PropagationDelay)
+	defer cancel()
+
+	cert, err := m.dns01Cached(ctx, ck, certName)
+	if err != nil {
+		log.Errorx("using cached certificate, requesting new certificate", err)
+	}
+	if cert == nil {
+		cert, err = m.dns01Request(ctx, log, d, ck, certName, keyType)
+		if err != nil {
+			return nil, err
+		}
+	}
+	c.cert = cert
+	m.dns01ScheduleRenew(c, d, ck, certName, keyType)
+	return cert, nil
+}
+
+// dns01Cached returns the certificate from the cache, or nil if it is absent or
+// expired.
+func (m *Manager) dns01Cached(ctx context.Context, ck, certName string) (*tls.Certificate, error) {
+	data, err := m.Manager.Cache.Get(ctx, ck)
+	if err != nil && errors.Is(err, autocert.ErrCacheMiss) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	// The cached keycert is of the form: private key, leaf certificate, intermediate
+	// certificates...
+	privb, rem := pem.Decode(data)
+	if privb == nil {
+		return nil, fmt.Errorf("missing private key in cached keycert file")
+	}
+	cert, err := tls.X509KeyPair(rem, pem.EncodeToMemory(privb))
+	if err != nil {
+		return nil, fmt.Errorf("parsing cached keycert file: %v", err)
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %v", err)
+	}
+	if !slices.Contains(cert.Leaf.DNSNames, certName) {
+		return nil, fmt.Errorf("cached certificate is for %v, not %s", cert.Leaf.DNSNames, certName)
+	}
+	if time.Now().After(cert.Leaf.NotAfter) {
+		return nil, nil
+	}
+	return &cert, nil
+}
+
+// dns01Request requests a certificate for certName through an ACME order with a
+// DNS-01 challenge, and stores it in the cache.
+func (m *Manager) dns01Request(ctx context.Context, log mlog.Log, d *DNS01, ck, certName string, keyType autocert.KeyType) (rcert *tls.Certificate, rerr error) {
+	log.Info("requesting certificate with dns-01")
+	defer func() {
+		if rerr != nil {
+			metricCertRequestErrors.Inc()
+			log.Errorx("requesting certificate with dns-01", rerr)
+		}
+	}()
+
+	if err := m.dns01Register(ctx); err != nil {
+		return nil, err
+	}
+
+	client := m.Manager.Client
+	order, err := client.AuthorizeOrder(ctx, acme.DomainIDs(certName))
+	if err != nil {
+		return nil, fmt.Errorf("creating order: %w", err)
+	}
+	for _, zurl := range order.AuthzURLs {
+		if err := m.dns01Authorize(ctx, log, d, zurl); err != nil {
+			return nil, err
+		}
+	}
+	order, err = client.WaitOrder(ctx, order.URI)
+	if err != nil {
+		return nil, fmt.Errorf("waiting for order: %w", err)
+	}
+
+	key, err := m.Manager.GetPrivateKey(certName, keyType)
+	if err != nil {
+		return nil, fmt.Errorf("get private key: %v", err)
+	}
+	csr, err := x509.CreateCertificateRequest(cryptorand.Reader, &x509.CertificateRequest{DNSNames: []string{certName}}, key)
+	if err != nil {
+		return nil, fmt.Errorf("creating certificate request: %v", err)
+	}
+	der, _, err := client.CreateOrderCert(ctx, order.FinalizeURL, csr, true)
+	if err != nil {
+		return nil, fmt.Errorf("finalizing order: %w", err)
+	}
+	if len(der) == 0 {
+		return nil, fmt.Errorf("no certificate in response")
+	}
+	leaf, err := x509.ParseCertificate(der[0])
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %v", err)
+	}
+	if !slices.Contains(leaf.DNSNames, certName) {
+		return nil, fmt.Errorf("certificate is for %v, not %s", leaf.DNSNames, certName)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("marshal private key: %v", err)
+	}
+	var b bytes.Buffer
+	if err := pem.Encode(&b, &pem.Block{Type: "PRIVATE KEY", Bytes: privDER}); err != nil {
+		return nil, fmt.Errorf("pem encode private key: %v", err)
+	}
+	for _, buf := range der {
+		if err := pem.Encode(&b, &pem.Block{Type: "CERTIFICATE", Bytes: buf}); err != nil {
+			return nil, fmt.Errorf("pem encode certificate: %v", err)
+		}
+	}
+	if err := m.Manager.Cache.Put(ctx, ck, b.Bytes()); err != nil {
+		return nil, fmt.Errorf("storing certificate: %v", err)
+	}
+
+	log.Info("certificate requested with dns-01", slog.Time("notafter", leaf.NotAfter))
+	return &tls.Certificate{Certificate: der, PrivateKey: key, Leaf: leaf}, nil
+}
+
+// dns01Authorize completes the DNS-01 challenge of an authorization, setting and
+// afterwards removing the TXT record.
+func (m *Manager) dns01Authorize(ctx context.Context, log mlog.Log, d *DNS01, zurl string) error {
+	client := m.Manager.Client
+	z, err := client.GetAuthorization(ctx, zurl)
+	if err != nil {
+		return fmt.Errorf("get authorization: %w", err)
+	}
+	if z.Status == acme.StatusValid {
+		return nil
+	}
+	var chal *acme.Challenge
+	for _, c := range z.Challenges {
+		if c.Type == "dns-01" {
+			chal = c
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("no dns-01 challenge offered for %s", z.Identifier.Value)
+	}
+	value, err := client.DNS01ChallengeRecord(chal.Token)
+	if err != nil {
+		return fmt.Errorf("dns-01 challenge record: %v", err)
+	}
+
+	name := "_acme-challenge." + z.Identifier.Value
+	if err := d.SetTXT(ctx, name, []string{value}); err != nil {
+		return fmt.Errorf("setting txt record for dns-01 challenge: %w", err)
+	}
+	defer func() {
+		// Remove the record, also when the context has expired.
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+		err := d.SetTXT(ctx, name, nil)
+		log.Check(err, "removing txt record for dns-01 challenge", slog.String("name", name))
+	}()
+
+	select {
+	case <-time.After(d.PropagationDelay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if _, err := client.Accept(ctx, chal); err != nil {
+		return fmt.Errorf("accepting dns-01 challenge: %w", err)
+	}
+	if _, err := client.WaitAuthorization(ctx, z.URI); err != nil {
+		return fmt.Errorf("waiting for authorization: %w", err)
+	}
+	return nil
+}
+
+// dns01Register ensures an ACME account exists for the key of the client.
+func (m *Manager) dns01Register(ctx context.Context) error {
+	client := m.Manager.Client
+	if _, err := client.GetReg(ctx, ""); err == nil {
+		return nil
+	} else if !errors.Is(err, acme.ErrNoAccount) {
+		return fmt.Errorf("get acme account: %w", err)
+	}
+	a := &acme.Account{
+		Contact:                []string{"mailto:" + m.Manager.Email},
+		ExternalAccountBinding: m.Manager.ExternalAccountBinding,
+	}
+	if _, err := client.Register(ctx, a, autocert.AcceptTOS); err != nil && !errors.Is(err, acme.ErrAccountAlreadyExists) {
+		return fmt.Errorf("register acme account: %w", err)
+	}
+	return nil
+}
+
+// dns01ScheduleRenew starts a timer to renew the certificate before it expires.
+// Must be called with c locked.
+func (m *Manager) dns01ScheduleRenew(c *dns01Cert, d *DNS01, ck, certName string, keyType autocert.KeyType) {
+	leaf := c.cert.Leaf
+	before := min(dns01RenewBefore, leaf.NotAfter.Sub(leaf.NotBefore)/3)
+	m.dns01RenewAfter(c, time.Until(leaf.NotAfter.Add(-before)), d, ck, certName, keyType)
+}
+
+// dns01RenewAfter requests a new certificate after delay. On failure, the current
+// certificate is kept and the renewal is retried an hour later. Must be called
+// with c locked.
+func (m *Manager) dns01RenewAfter(c *dns01Cert, delay time.Duration, d *DNS01, ck, certName string, keyType autocert.KeyType) {
+	if c.renew != nil {
+		c.renew.Stop()
+	}
+	c.renew = time.AfterFunc(delay, func() {
+		select {
+		case <-m.shutdown:
+			return
+		default:
+		}
+
+		log := mlog.New("autotls", nil).With(slog.String("certname", certName))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute+d.PropagationDelay)
+		defer cancel()
+		cert, err := m.dns01Request(ctx, log, d, ck, certName, keyType)
+
+		c.Lock()
+		defer c.Unlock()
+		if err != nil {
+			m.dns01RenewAfter(c, time.Hour, d, ck, certName, keyType)
+			return
+		}
+		c.cert = cert
+		m.dns01ScheduleRenew(c, d, ck, certName, keyType)
+	})
+}
+
+// supportsECDSA returns whether the client supports ECDSA certificates. From
+// autocert, which does not export it.
+func supportsECDSA(hello *tls.ClientHelloInfo) bool {
+	// The "signature_algorithms" extension, if present, limits the key exchange
+	// algorithms allowed by the cipher suites. See RFC 5246, section 7.4.1.4.1.
+	if hello.SignatureSchemes != nil {
+		ecdsaOK := false
+	schemeLoop:
+		for _, scheme := range hello.SignatureSchemes {
+			const tlsECDSAWithSHA1 tls.SignatureScheme = 0x0203 // constant added in Go 1.10
+			switch scheme {
+			case tlsECDSAWithSHA1, tls.ECDSAWithP256AndSHA256,
+				tls.ECDSAWithP384AndSHA384, tls.ECDSAWithP521AndSHA512:
+				ecdsaOK = true
+				break schemeLoop
+			}
+		}
+		if !ecdsaOK {
+			return false
+		}
+	}
+	if hello.SupportedCurves != nil {
+		ecdsaOK := false
+		for _, curve := range hello.SupportedCurves {
+			if curve == tls.CurveP256 {
+				ecdsaOK = true
+				break
+			}
+		}
+		if !ecdsaOK {
+			return false
+		}
+	}
+	for _, suite := range hello.CipherSuites {
+		switch suite {
+		case tls.TLS_ECDHE_ECDSA_WITH_RC4_128_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305:
+			return true
+		}
+	}
+	return false
+}
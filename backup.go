@@ -18,11 +18,16 @@ import (
 
 	"github.com/mjl-/bstore"
 
+	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dmarcdb"
+	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/moxvar"
 	"github.com/mjl-/mox/mtastsdb"
+	"github.com/mjl-/mox/postmasterdb"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/tlsrptdb"
 )
@@ -383,6 +388,9 @@ func xbackupctl(ctx context.Context, xctl *ctl) {
 	backupDB(mtastsdb.DB, "mtasts.db")
 	backupDB(tlsrptdb.ReportDB, "tlsrpt.db")
 	backupDB(tlsrptdb.ResultDB, "tlsrptresult.db")
+	backupDB(postmasterdb.DB, "postmaster.db")
+	backupDB(reputationdb.DB, "reputation.db")
+	backupDB(mlist.DB, "mlist.db")
 	backupFile("receivedid.key")
 
 	// Acme directory is optional.
@@ -439,6 +447,23 @@ func xbackupctl(ctx context.Context, xctl *ctl) {
 			}
 			return nil
 		})
+		if err == nil {
+			// Messages in the dead-letter queue have their own directory.
+			err = bstore.QueryDB[queue.MsgDead](ctx, db).ForEach(func(m queue.MsgDead) error {
+				mp := filepath.Join("dead", store.MessagePath(m.ID))
+				seen[mp] = struct{}{}
+				srcpath := filepath.Join(srcDataDir, "queue", mp)
+				dstpath := filepath.Join(dstDataDir, "queue", mp)
+				if linked, err := linkOrCopy(srcpath, dstpath); err != nil {
+					xerrx("linking/copying dead-letter queue message", err, slog.String("srcpath", srcpath), slog.String("dstpath", dstpath))
+				} else if linked {
+					nlinked++
+				} else {
+					ncopied++
+				}
+				return nil
+			})
+		}
 		if err != nil {
 			xerrx("processing queue messages (not backed up properly)", err, slog.Duration("duration", time.Since(tmMsgs)))
 		} else {
@@ -641,6 +666,29 @@ func xbackupctl(ctx context.Context, xctl *ctl) {
 		backupAccount(acc)
 	}
 
+	// Copy shared junk filters of domains, if present. Tracked to skip them when
+	// handling "all other files" below.
+	sharedJunkFilters := map[string]struct{}{}
+	for _, dom := range mox.Conf.DomainConfigs() {
+		jfpath := filepath.Join("junkfilter", dom.Domain.Name()+".db")
+		if dom.SharedJunkFilter == nil {
+			continue
+		} else if _, err := os.Stat(filepath.Join(srcDataDir, jfpath)); err != nil {
+			continue
+		}
+		err := store.WithSharedJunkFilter(ctx, xctl.log, dom.Domain, func(jf *junk.Filter, _ config.SharedJunkFilter) error {
+			backupDB(jf.DB(), jfpath)
+			bloompath := filepath.Join("junkfilter", dom.Domain.Name()+".bloom")
+			backupFile(bloompath)
+			sharedJunkFilters[jfpath] = struct{}{}
+			sharedJunkFilters[bloompath] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			xerrx("opening shared junk filter (will try to copy as regular files later)", err, slog.String("domain", dom.Domain.Name()))
+		}
+	}
+
 	// Copy all other files, that aren't part of the known files, databases, queue or accounts.
 	tmWalk := time.Now()
 	err = filepath.WalkDir(srcDataDir, func(srcpath string, d fs.DirEntry, err error) error {
@@ -653,7 +701,9 @@ func xbackupctl(ctx context.Context, xctl *ctl) {
 			return nil
 		}
 		p := srcpath[len(srcDataDir)+1:]
-		if p == "queue" || p == "acme" || p == "tmp" {
+		// Files in the dedup directory are hard linked into the accounts, no need to back
+		// them up separately.
+		if p == "queue" || p == "acme" || p == "tmp" || p == "dedup" {
 			return fs.SkipDir
 		}
 		l := strings.Split(p, string(filepath.Separator))
@@ -668,9 +718,12 @@ func xbackupctl(ctx context.Context, xctl *ctl) {
 		if d.IsDir() {
 			return nil
 		}
+		if _, ok := sharedJunkFilters[p]; ok {
+			return nil
+		}
 
 		switch p {
-		case "auth.db", "dmarcrpt.db", "dmarceval.db", "mtasts.db", "tlsrpt.db", "tlsrptresult.db", "receivedid.key", "ctl":
+		case "auth.db", "dmarcrpt.db", "dmarceval.db", "mtasts.db", "tlsrpt.db", "tlsrptresult.db", "postmaster.db", "reputation.db", "mlist.db", "receivedid.key", "ctl":
 			// Already handled.
 			return nil
 		case "lastknownversion": // Optional file, not yet handled.
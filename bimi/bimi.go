@@ -0,0 +1,203 @@
+// Package bimi implements BIMI (Brand Indicators for Message Identification)
+// record lookup and evaluation.
+//
+// With BIMI, a domain publishes the location of its logo in DNS, as TXT record
+// under "<selector>._bimi.<domain>". Receiving mail servers only use the logo of
+// messages that pass DMARC for a domain with an enforced DMARC policy, i.e.
+// quarantine (for all messages) or reject, so only authenticated messages are
+// shown with the logo. The record can reference a Verified Mark Certificate (VMC)
+// that ties the logo to the domain. Certificates are not verified by this
+// package.
+package bimi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mjl-/mox/dmarc"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/publicsuffix"
+)
+
+// Lookup errors.
+var (
+	ErrNoRecord        = errors.New("bimi: no bimi dns record")
+	ErrMultipleRecords = errors.New("bimi: multiple bimi dns records")
+	ErrDNS             = errors.New("bimi: dns lookup")
+	ErrSyntax          = errors.New("bimi: malformed bimi record")
+)
+
+// DefaultSelector is used when a message does not have a BIMI-Selector header.
+const DefaultSelector = "default"
+
+// Status is the result of BIMI evaluation, for use in an Authentication-Results
+// header.
+type Status string
+
+const (
+	StatusPass      Status = "pass"      // Logo location found for message with enforced DMARC pass.
+	StatusNone      Status = "none"      // No BIMI record found.
+	StatusFail      Status = "fail"      // Malformed BIMI record.
+	StatusTemperror Status = "temperror" // Typically a DNS lookup error.
+	StatusDeclined  Status = "declined"  // Domain published a record without logo location.
+	StatusSkipped   Status = "skipped"   // Not evaluated, message did not pass DMARC with an enforced policy.
+)
+
+// Result is a BIMI evaluation.
+type Result struct {
+	Status Status
+	// Domain with the BIMI record, can be the organizational domain instead of the
+	// message From domain.
+	Domain   dns.Domain
+	Selector string
+	Record   *Record
+	// Whether the DNS response for the BIMI record was DNSSEC-signed.
+	Authentic bool
+	// Details about possible error condition, e.g. when parsing the record failed or
+	// why evaluation was skipped.
+	Err error
+}
+
+// Lookup looks up the BIMI TXT record at "<selector>._bimi.<domain>" for the
+// domain in the "From"-header of a message, falling back to the organizational
+// domain if no record exists. An empty selector means the default selector.
+//
+// domain is the domain with the BIMI record.
+func Lookup(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, selector string, msgFrom dns.Domain) (domain dns.Domain, record *Record, txt string, authentic bool, rerr error) {
+	log := mlog.New("bimi", elog)
+	start := time.Now()
+	defer func() {
+		log.Debugx("bimi lookup result", rerr,
+			slog.Any("fromdomain", msgFrom),
+			slog.String("selector", selector),
+			slog.Any("domain", domain),
+			slog.Any("record", record),
+			slog.Duration("duration", time.Since(start)))
+	}()
+
+	if selector == "" {
+		selector = DefaultSelector
+	}
+	domain = msgFrom
+	record, txt, authentic, rerr = lookupRecord(ctx, resolver, selector, domain)
+	if rerr != ErrNoRecord {
+		return domain, record, txt, authentic, rerr
+	}
+	orgDomain := publicsuffix.Lookup(ctx, log.Logger, msgFrom)
+	if orgDomain == msgFrom {
+		return domain, nil, "", authentic, rerr
+	}
+	domain = orgDomain
+	var xauth bool
+	record, txt, xauth, rerr = lookupRecord(ctx, resolver, selector, domain)
+	return domain, record, txt, authentic && xauth, rerr
+}
+
+func lookupRecord(ctx context.Context, resolver dns.Resolver, selector string, domain dns.Domain) (*Record, string, bool, error) {
+	name := selector + "._bimi." + domain.ASCII + "."
+	txts, result, err := dns.WithPackage(resolver, "bimi").LookupTXT(ctx, name)
+	if dns.IsNotFound(err) {
+		return nil, "", result.Authentic, ErrNoRecord
+	} else if err != nil {
+		return nil, "", result.Authentic, fmt.Errorf("%w: %s", ErrDNS, err)
+	}
+	var record *Record
+	var text string
+	for _, txt := range txts {
+		r, isbimi, err := ParseRecord(txt)
+		if !isbimi {
+			continue
+		} else if err != nil {
+			return nil, txt, result.Authentic, err
+		}
+		if record != nil {
+			return nil, "", result.Authentic, ErrMultipleRecords
+		}
+		record = r
+		text = txt
+	}
+	if record == nil {
+		return nil, "", result.Authentic, ErrNoRecord
+	}
+	return record, text, result.Authentic, nil
+}
+
+// enforced returns whether the DMARC record has a policy for msgFrom, found at
+// domain, that is quarantine for all messages or reject.
+func enforced(r *dmarc.Record, domain, msgFrom dns.Domain) bool {
+	policy := r.Policy
+	if domain != msgFrom && r.SubdomainPolicy != "" {
+		policy = r.SubdomainPolicy
+	}
+	return policy == dmarc.PolicyReject || policy == dmarc.PolicyQuarantine && r.Percentage == 100
+}
+
+// Verify evaluates BIMI for a message with msgFrom as domain of the "From"-header,
+// and selector from the BIMI-Selector header (empty for the default selector).
+//
+// BIMI is only evaluated for messages that passed DMARC, given in dmarcResult,
+// with an enforced DMARC policy for both the From domain and its organizational
+// domain. Otherwise the result has status skipped, and no BIMI DNS lookups are
+// done.
+func Verify(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, msgFrom dns.Domain, selector string, dmarcResult dmarc.Result) (result Result) {
+	log := mlog.New("bimi", elog)
+	defer func() {
+		log.Debugx("bimi verify result", result.Err,
+			slog.Any("fromdomain", msgFrom),
+			slog.Any("status", result.Status),
+			slog.Any("domain", result.Domain))
+	}()
+
+	if selector == "" {
+		selector = DefaultSelector
+	}
+	result = Result{Selector: selector}
+
+	if dmarcResult.Status != dmarc.StatusPass || dmarcResult.Record == nil {
+		result.Status = StatusSkipped
+		result.Err = errors.New("message did not pass dmarc")
+		return
+	}
+	if !enforced(dmarcResult.Record, dmarcResult.Domain, msgFrom) {
+		result.Status = StatusSkipped
+		result.Err = errors.New("dmarc policy is not enforced")
+		return
+	}
+	// If the DMARC record was found at the From domain, the policy of the
+	// organizational domain must be enforced too.
+	if orgDomain := publicsuffix.Lookup(ctx, log.Logger, msgFrom); dmarcResult.Domain == msgFrom && orgDomain != msgFrom {
+		_, domain, record, _, _, err := dmarc.Lookup(ctx, log.Logger, resolver, orgDomain)
+		if err != nil && !errors.Is(err, dmarc.ErrNoRecord) {
+			result.Status = StatusTemperror
+			result.Err = fmt.Errorf("looking up dmarc record of organizational domain: %w", err)
+			return
+		} else if record == nil || !enforced(record, domain, msgFrom) {
+			result.Status = StatusSkipped
+			result.Err = errors.New("dmarc policy of organizational domain is not enforced")
+			return
+		}
+	}
+
+	domain, record, _, authentic, err := Lookup(ctx, log.Logger, resolver, selector, msgFrom)
+	result.Domain = domain
+	result.Record = record
+	result.Authentic = authentic
+	result.Err = err
+	switch {
+	case err == ErrNoRecord:
+		result.Status = StatusNone
+	case errors.Is(err, ErrDNS):
+		result.Status = StatusTemperror
+	case err != nil:
+		result.Status = StatusFail
+	case record.Location == "":
+		result.Status = StatusDeclined
+	default:
+		result.Status = StatusPass
+	}
+	return
+}
@@ -0,0 +1,111 @@
+package bimi
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mjl-/mox/dmarc"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+)
+
+var pkglog = mlog.New("bimi", nil)
+
+func TestParse(t *testing.T) {
+	test := func(s string, expRecord *Record, expBIMI bool, expErr error) {
+		t.Helper()
+		r, isbimi, err := ParseRecord(s)
+		if (err == nil) != (expErr == nil) || err != nil && !errors.Is(err, expErr) {
+			t.Fatalf("parsing %q: got err %v, expected %v", s, err, expErr)
+		}
+		if isbimi != expBIMI || !reflect.DeepEqual(r, expRecord) {
+			t.Fatalf("parsing %q: got %#v, isbimi %v, expected %#v, %v", s, r, isbimi, expRecord, expBIMI)
+		}
+	}
+
+	test("v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem", &Record{"BIMI1", "https://example.com/logo.svg", "https://example.com/vmc.pem"}, true, nil)
+	test("v=BIMI1;l=https://example.com/logo.svg", &Record{"BIMI1", "https://example.com/logo.svg", ""}, true, nil)
+	test("v=BIMI1; l=; a=;", &Record{Version: "BIMI1"}, true, nil)
+	test("v=BIMI1; L=https://example.com/logo.svg; other=x", &Record{"BIMI1", "https://example.com/logo.svg", ""}, true, nil)
+	test("v=BIMI1; l=http://example.com/logo.svg", nil, true, ErrSyntax)
+	test("v=BIMI1; l=a; l=b", nil, false, ErrSyntax)
+	test("v=spf1 -all", nil, false, ErrSyntax)
+	test("l=https://example.com/logo.svg; v=BIMI1", nil, false, ErrSyntax)
+
+	r := Record{"BIMI1", "https://example.com/logo.svg", "https://example.com/vmc.pem"}
+	if s := r.String(); s != "v=BIMI1; l=https://example.com/logo.svg; a=https://example.com/vmc.pem" {
+		t.Fatalf("got record string %q", s)
+	}
+	if xr, _, err := ParseRecord(r.String()); err != nil || *xr != r {
+		t.Fatalf("parsing record string: got %#v, err %v", xr, err)
+	}
+
+	sel, err := ParseSelector("v=BIMI1; s=Brand")
+	if err != nil || sel != "brand" {
+		t.Fatalf("parsing selector: got %q, err %v", sel, err)
+	}
+	for _, s := range []string{"v=BIMI1", "s=brand", "v=BIMI1; s=", "v=BIMI1; s=a.b"} {
+		if _, err := ParseSelector(s); err == nil {
+			t.Fatalf("parsing selector %q: expected error", s)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	resolver := dns.MockResolver{
+		TXT: map[string][]string{
+			"default._bimi.example.com.":  {"v=BIMI1; l=https://example.com/logo.svg"},
+			"brand._bimi.example.com.":    {"v=BIMI1; l=https://example.com/brand.svg"},
+			"default._bimi.declined.com.": {"v=BIMI1; l=;"},
+			"default._bimi.bad.com.":      {"v=BIMI1; l=http://bad.com/logo.svg"},
+			"default._bimi.multiple.com.": {"v=BIMI1; l=https://multiple.com/a.svg", "v=BIMI1; l=https://multiple.com/b.svg"},
+			"_dmarc.example.com.":         {"v=DMARC1; p=reject"},
+			"_dmarc.orgnone.com.":         {"v=DMARC1; p=none"},
+		},
+		Fail: []string{
+			"txt default._bimi.temperror.com.",
+		},
+	}
+
+	dmarcResult := func(domain string, policy, subdomainPolicy dmarc.Policy, pct int) dmarc.Result {
+		r := dmarc.DefaultRecord
+		r.Policy = policy
+		r.SubdomainPolicy = subdomainPolicy
+		r.Percentage = pct
+		return dmarc.Result{Status: dmarc.StatusPass, Domain: dns.Domain{ASCII: domain}, Record: &r}
+	}
+
+	test := func(from, selector string, dr dmarc.Result, expStatus Status, expDomain, expLocation string) {
+		t.Helper()
+		r := Verify(context.Background(), pkglog.Logger, resolver, dns.Domain{ASCII: from}, selector, dr)
+		if r.Status != expStatus || r.Domain != (dns.Domain{ASCII: expDomain}) {
+			t.Fatalf("verify %s: got status %q, domain %v, err %v, expected %q, %s", from, r.Status, r.Domain, r.Err, expStatus, expDomain)
+		}
+		if expLocation != "" && (r.Record == nil || r.Record.Location != expLocation) {
+			t.Fatalf("verify %s: got record %#v, expected location %q", from, r.Record, expLocation)
+		}
+	}
+
+	test("example.com", "", dmarcResult("example.com", dmarc.PolicyReject, "", 100), StatusPass, "example.com", "https://example.com/logo.svg")
+	test("example.com", "brand", dmarcResult("example.com", dmarc.PolicyQuarantine, "", 100), StatusPass, "example.com", "https://example.com/brand.svg")
+	test("example.com", "", dmarcResult("example.com", dmarc.PolicyQuarantine, "", 50), StatusSkipped, "", "")
+	test("example.com", "", dmarcResult("example.com", dmarc.PolicyNone, "", 100), StatusSkipped, "", "")
+	test("example.com", "", dmarc.Result{Status: dmarc.StatusFail}, StatusSkipped, "", "")
+
+	// Record and policy at organizational domain, with subdomain policy.
+	test("sub.example.com", "", dmarcResult("example.com", dmarc.PolicyReject, "", 100), StatusPass, "example.com", "https://example.com/logo.svg")
+	test("sub.example.com", "", dmarcResult("example.com", dmarc.PolicyReject, dmarc.PolicyNone, 100), StatusSkipped, "", "")
+
+	// Policy at subdomain is enforced, but not at organizational domain.
+	test("sub.orgnone.com", "", dmarcResult("sub.orgnone.com", dmarc.PolicyReject, "", 100), StatusSkipped, "", "")
+	// Policy at subdomain, organizational domain enforced.
+	test("sub.example.com", "", dmarcResult("sub.example.com", dmarc.PolicyReject, "", 100), StatusPass, "example.com", "https://example.com/logo.svg")
+
+	test("absent.com", "", dmarcResult("absent.com", dmarc.PolicyReject, "", 100), StatusNone, "absent.com", "")
+	test("declined.com", "", dmarcResult("declined.com", dmarc.PolicyReject, "", 100), StatusDeclined, "declined.com", "")
+	test("bad.com", "", dmarcResult("bad.com", dmarc.PolicyReject, "", 100), StatusFail, "bad.com", "")
+	test("multiple.com", "", dmarcResult("multiple.com", dmarc.PolicyReject, "", 100), StatusFail, "multiple.com", "")
+	test("temperror.com", "", dmarcResult("temperror.com", dmarc.PolicyReject, "", 100), StatusTemperror, "temperror.com", "")
+}
@@ -0,0 +1,105 @@
+package bimi
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Record is a BIMI DNS TXT record, published at "<selector>._bimi.<domain>".
+type Record struct {
+	Version   string // "v=BIMI1", fixed.
+	Location  string // "l=", HTTPS URL of the SVG logo. Empty means the domain declines to publish a logo.
+	Authority string // "a=", optional HTTPS URL of the PEM file with the Verified Mark Certificate (VMC).
+}
+
+// String returns the BIMI record for use as DNS TXT record.
+func (r Record) String() string {
+	s := fmt.Sprintf("v=%s; l=%s", r.Version, r.Location)
+	if r.Authority != "" {
+		s += "; a=" + r.Authority
+	}
+	return s
+}
+
+// ParseRecord parses a BIMI DNS TXT record. The same syntax is used for the
+// BIMI-Location message header.
+//
+// isbimi indicates if the record starts with tag "v" with value "BIMI1", and
+// should be treated as a BIMI record. Used to detect possibly multiple BIMI
+// records for a name with multiple TXT records.
+func ParseRecord(s string) (record *Record, isbimi bool, err error) {
+	tags, err := parseTags(s)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(tags) == 0 || tags[0][0] != "v" || tags[0][1] != "BIMI1" {
+		return nil, false, fmt.Errorf("%w: record must start with v=BIMI1", ErrSyntax)
+	}
+	r := &Record{Version: "BIMI1"}
+	for _, t := range tags[1:] {
+		switch t[0] {
+		case "l":
+			r.Location = t[1]
+		case "a":
+			r.Authority = t[1]
+		}
+	}
+	for _, u := range []string{r.Location, r.Authority} {
+		if u == "" {
+			continue
+		}
+		if pu, err := url.Parse(u); err != nil || pu.Scheme != "https" || pu.Host == "" {
+			return nil, true, fmt.Errorf("%w: url %q must be an absolute https url", ErrSyntax, u)
+		}
+	}
+	return r, true, nil
+}
+
+// ParseSelector parses the value of a BIMI-Selector message header, e.g.
+// "v=BIMI1; s=brand", returning the selector.
+func ParseSelector(s string) (string, error) {
+	tags, err := parseTags(s)
+	if err != nil {
+		return "", err
+	}
+	if len(tags) == 0 || tags[0][0] != "v" || tags[0][1] != "BIMI1" {
+		return "", fmt.Errorf("%w: header must start with v=BIMI1", ErrSyntax)
+	}
+	for _, t := range tags[1:] {
+		if t[0] == "s" {
+			if t[1] == "" || strings.ContainsAny(t[1], " \t.") {
+				return "", fmt.Errorf("%w: invalid selector %q", ErrSyntax, t[1])
+			}
+			return strings.ToLower(t[1]), nil
+		}
+	}
+	return "", fmt.Errorf("%w: missing selector", ErrSyntax)
+}
+
+// parseTags parses a list of tag=value pairs separated by semicolons. Tag names
+// are returned in lower case, duplicate tags are an error.
+func parseTags(s string) ([][2]string, error) {
+	var tags [][2]string
+	seen := map[string]bool{}
+	for _, t := range strings.Split(s, ";") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: missing = in tag %q", ErrSyntax, t)
+		}
+		k = strings.ToLower(strings.TrimSpace(k))
+		if k == "" {
+			return nil, fmt.Errorf("%w: empty tag name", ErrSyntax)
+		}
+		if seen[k] {
+			return nil, fmt.Errorf("%w: duplicate tag %q", ErrSyntax, k)
+		}
+		seen[k] = true
+		tags = append(tags, [2]string{k, strings.TrimSpace(v)})
+	}
+	return tags, nil
+}
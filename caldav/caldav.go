@@ -0,0 +1,967 @@
+// Package caldav implements a CalDAV server, for synchronizing the calendars of
+// an account with clients like DAVx5 and Apple Calendar.
+//
+// Calendars and their objects (events, todo's) are stored in the account
+// database. Each account has a scheduling inbox, to which iTIP messages (e.g.
+// meeting invitations) from delivered email are added, see Deliver. Changes to
+// events from validated senders are applied to existing events. The scheduling
+// outbox is listed for clients, but cannot be used for sending: clients send
+// invitations by email.
+//
+// URLs are relative to the configured path, typically /caldav/:
+//
+//	principal/ - Principal of the account.
+//	calendars/ - Home with calendar collections.
+//	calendars/<name>/ - Calendar collection, including "inbox" and "outbox".
+//	calendars/<name>/<object> - Calendar object resource.
+//
+// See RFC 4918 for WebDAV, RFC 4791 for CalDAV and RFC 6638 for scheduling
+// extensions.
+package caldav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dav"
+	"github.com/mjl-/mox/ical"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+var pkglog = mlog.New("caldav", nil)
+
+var (
+	metricRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_caldav_requests_total",
+			Help: "CalDAV requests by method and HTTP response status code.",
+		},
+		[]string{"method", "code"},
+	)
+)
+
+// Names of the scheduling calendars, which cannot be created, changed or removed
+// by clients.
+const (
+	inboxName  = "inbox"
+	outboxName = "outbox"
+
+	// Calendar created for accounts without calendars.
+	defaultName = "default"
+)
+
+// Maximum size of a calendar object.
+const maxObjectSize = 1024 * 1024
+
+// NewServer returns a CalDAV HTTP handler. Path is the path the handler is
+// configured under, with trailing slash, used in hrefs in responses. Requests
+// must have the path stripped. If isForwarded is set, the remote IP for rate
+// limiting authentication failures is taken from the X-Forwarded-For header.
+func NewServer(path string, isForwarded bool) http.Handler {
+	return server{path, isForwarded}
+}
+
+type server struct {
+	path        string
+	isForwarded bool
+}
+
+// kind of resource a URL path refers to.
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindPrincipal
+	kindHome
+	kindCalendar
+	kindObject
+)
+
+// resource is the target of a request, or a child in a PROPFIND response.
+type resource struct {
+	kind    kind
+	calName string                // For kindCalendar and kindObject.
+	objName string                // For kindObject.
+	cal     *store.Calendar       // If calendar exists.
+	obj     *store.CalendarObject // If object exists.
+}
+
+// parsePath parses a path with the configured path already stripped.
+func parsePath(p string) (resource, bool) {
+	t := strings.Split(strings.Trim(p, "/"), "/")
+	switch {
+	case len(t) == 1 && t[0] == "":
+		return resource{kind: kindRoot}, true
+	case len(t) == 1 && t[0] == "principal":
+		return resource{kind: kindPrincipal}, true
+	case len(t) == 1 && t[0] == "calendars":
+		return resource{kind: kindHome}, true
+	case len(t) == 2 && t[0] == "calendars" && t[1] != "":
+		return resource{kind: kindCalendar, calName: t[1]}, true
+	case len(t) == 3 && t[0] == "calendars" && t[1] != "" && t[2] != "" && !strings.HasSuffix(p, "/"):
+		return resource{kind: kindObject, calName: t[1], objName: t[2]}, true
+	}
+	return resource{}, false
+}
+
+// href returns the absolute path of a resource, as used in responses.
+func (s server) href(res resource) string {
+	switch res.kind {
+	case kindRoot:
+		return s.path
+	case kindPrincipal:
+		return s.path + "principal/"
+	case kindHome:
+		return s.path + "calendars/"
+	case kindCalendar:
+		return s.path + "calendars/" + res.calName + "/"
+	case kindObject:
+		return s.path + "calendars/" + res.calName + "/" + res.objName
+	}
+	panic("missing case")
+}
+
+// ServeHTTP implements http.Handler.
+func (s server) ServeHTTP(xw http.ResponseWriter, r *http.Request) {
+	log := pkglog.WithContext(r.Context()).With(slog.String("method", r.Method), slog.String("path", r.URL.Path))
+
+	w := &dav.StatusWriter{ResponseWriter: xw}
+	defer func() {
+		metricRequests.WithLabelValues(r.Method, strconv.Itoa(w.Status)).Inc()
+	}()
+
+	h := w.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "no-cache, max-age=0")
+
+	acc := dav.Authenticate(log, w, r, "caldav", s.isForwarded)
+	if acc == nil {
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	log = log.With(slog.String("account", acc.Name))
+
+	ctx := r.Context()
+	if err := ensureCalendars(ctx, acc); err != nil {
+		log.Errorx("ensuring calendars for account", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res, ok := parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if res.kind == kindCalendar || res.kind == kindObject {
+		err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+			return lookup(tx, &res)
+		})
+		if err != nil {
+			log.Errorx("looking up resource", err)
+			http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		h.Set("DAV", "1, calendar-access")
+		h.Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT, MKCALENDAR, MKCOL")
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		s.propfind(ctx, log, acc, w, r, res)
+	case "PROPPATCH":
+		s.proppatch(ctx, log, acc, w, r, res)
+	case "REPORT":
+		s.report(ctx, log, acc, w, r, res)
+	case "GET", "HEAD":
+		s.get(w, r, res)
+	case "PUT":
+		s.put(ctx, log, acc, w, r, res)
+	case "DELETE":
+		s.delete(ctx, log, acc, w, r, res)
+	case "MKCALENDAR", "MKCOL":
+		s.mkcalendar(ctx, log, acc, w, r, res)
+	case "POST":
+		if res.kind == kindCalendar && res.calName == outboxName {
+			http.Error(w, "403 - forbidden - scheduling through the outbox is not supported, send invitations by email", http.StatusForbidden)
+			return
+		}
+		http.Error(w, "405 - method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.Error(w, "405 - method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ensureCalendars adds the scheduling inbox and outbox, and a default calendar if
+// the account doesn't have other calendars.
+func ensureCalendars(ctx context.Context, acc *store.Account) error {
+	var names []string
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		return bstore.QueryTx[store.Calendar](tx).ForEach(func(c store.Calendar) error {
+			names = append(names, c.Name)
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("listing calendars: %w", err)
+	}
+	var missing []store.Calendar
+	if !slices.Contains(names, inboxName) {
+		missing = append(missing, store.Calendar{Name: inboxName, DisplayName: "Inbox"})
+	}
+	if !slices.Contains(names, outboxName) {
+		missing = append(missing, store.Calendar{Name: outboxName, DisplayName: "Outbox"})
+	}
+	if len(slices.DeleteFunc(names, func(s string) bool { return s == inboxName || s == outboxName })) == 0 {
+		missing = append(missing, store.Calendar{Name: defaultName, DisplayName: "Calendar"})
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, c := range missing {
+			err := tx.Insert(&c)
+			if err != nil && !errors.Is(err, bstore.ErrUnique) {
+				return fmt.Errorf("adding calendar %q: %w", c.Name, err)
+			}
+		}
+		return nil
+	})
+}
+
+// lookup sets the calendar and object of res, if they exist.
+func lookup(tx *bstore.Tx, res *resource) error {
+	cal, err := bstore.QueryTx[store.Calendar](tx).FilterNonzero(store.Calendar{Name: res.calName}).Get()
+	if err == bstore.ErrAbsent {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("looking up calendar: %w", err)
+	}
+	res.cal = &cal
+	if res.kind != kindObject {
+		return nil
+	}
+	obj, err := bstore.QueryTx[store.CalendarObject](tx).FilterNonzero(store.CalendarObject{CalendarID: cal.ID, Name: res.objName}).Get()
+	if err == bstore.ErrAbsent {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("looking up calendar object: %w", err)
+	}
+	res.obj = &obj
+	return nil
+}
+
+// exists returns whether the resource exists.
+func (res resource) exists() bool {
+	switch res.kind {
+	case kindCalendar:
+		return res.cal != nil
+	case kindObject:
+		return res.obj != nil
+	}
+	return true
+}
+
+// Names of properties.
+func calDAVName(local string) xml.Name { return xml.Name{Space: dav.NSCalDAV, Local: local} }
+
+var (
+	propResourceType         = dav.DAVName("resourcetype")
+	propDisplayName          = dav.DAVName("displayname")
+	propCurrentUserPrincipal = dav.DAVName("current-user-principal")
+	propPrivilegeSet         = dav.DAVName("current-user-privilege-set")
+	propPrincipalURL         = dav.DAVName("principal-URL")
+	propSupportedReportSet   = dav.DAVName("supported-report-set")
+	propGetETag              = dav.DAVName("getetag")
+	propGetContentType       = dav.DAVName("getcontenttype")
+	propGetContentLength     = dav.DAVName("getcontentlength")
+	propGetLastModified      = dav.DAVName("getlastmodified")
+	propCalendarHomeSet      = calDAVName("calendar-home-set")
+	propCalendarUserAddrSet  = calDAVName("calendar-user-address-set")
+	propScheduleInboxURL     = calDAVName("schedule-inbox-URL")
+	propScheduleOutboxURL    = calDAVName("schedule-outbox-URL")
+	propCalendarDescription  = calDAVName("calendar-description")
+	propSupportedCompSet     = calDAVName("supported-calendar-component-set")
+	propCalendarData         = calDAVName("calendar-data")
+	propCalendarColor        = xml.Name{Space: dav.NSAppleIC, Local: "calendar-color"}
+	propGetCTag              = xml.Name{Space: dav.NSCS, Local: "getctag"}
+)
+
+// properties returns the properties of an existing resource, for allprop and
+// for looking up requested properties. The calendar data of objects is only
+// included if withData is set.
+func (s server) properties(acc *store.Account, res resource, withData bool) []dav.Prop {
+	privileges := "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege><d:privilege><d:write-properties/></d:privilege><d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>"
+	if res.kind == kindCalendar && (res.calName == inboxName || res.calName == outboxName) {
+		privileges = "<d:privilege><d:read/></d:privilege><d:privilege><d:unbind/></d:privilege>"
+	}
+
+	var l []dav.Prop
+	add := func(name xml.Name, value string) {
+		l = append(l, dav.Prop{Name: name, Value: value})
+	}
+	add(propCurrentUserPrincipal, dav.Href(s.href(resource{kind: kindPrincipal})))
+	add(propPrivilegeSet, privileges)
+
+	switch res.kind {
+	case kindRoot:
+		add(propResourceType, "<d:collection/>")
+	case kindPrincipal:
+		conf, _ := acc.Conf()
+		var addrs string
+		for addr := range conf.Destinations {
+			if !strings.HasPrefix(addr, "@") {
+				addrs += dav.Href("mailto:" + addr)
+			}
+		}
+		addrs += dav.Href(s.href(resource{kind: kindPrincipal}))
+		displayName := conf.FullName
+		if displayName == "" {
+			displayName = acc.Name
+		}
+		add(propResourceType, "<d:collection/><d:principal/>")
+		add(propDisplayName, dav.Escape(displayName))
+		add(propPrincipalURL, dav.Href(s.href(res)))
+		add(propCalendarHomeSet, dav.Href(s.href(resource{kind: kindHome})))
+		add(propCalendarUserAddrSet, addrs)
+		add(propScheduleInboxURL, dav.Href(s.href(resource{kind: kindCalendar, calName: inboxName})))
+		add(propScheduleOutboxURL, dav.Href(s.href(resource{kind: kindCalendar, calName: outboxName})))
+	case kindHome:
+		add(propResourceType, "<d:collection/>")
+		add(propDisplayName, "Calendars")
+	case kindCalendar:
+		switch res.calName {
+		case inboxName:
+			add(propResourceType, "<d:collection/><c:schedule-inbox/>")
+		case outboxName:
+			add(propResourceType, "<d:collection/><c:schedule-outbox/>")
+		default:
+			add(propResourceType, "<d:collection/><c:calendar/>")
+		}
+		displayName := res.cal.DisplayName
+		if displayName == "" {
+			displayName = res.cal.Name
+		}
+		add(propDisplayName, dav.Escape(displayName))
+		add(propGetCTag, strconv.FormatInt(res.cal.ModSeq, 10))
+		add(propCalendarDescription, dav.Escape(res.cal.Description))
+		add(propCalendarColor, dav.Escape(res.cal.Color))
+		add(propSupportedCompSet, `<c:comp name="VEVENT"/><c:comp name="VTODO"/>`)
+		add(propSupportedReportSet, `<d:supported-report><d:report><c:calendar-multiget/></d:report></d:supported-report><d:supported-report><d:report><c:calendar-query/></d:report></d:supported-report>`)
+	case kindObject:
+		add(propResourceType, "")
+		add(propGetETag, dav.Escape(`"`+res.obj.ETag+`"`))
+		add(propGetContentType, "text/calendar; charset=utf-8")
+		add(propGetContentLength, strconv.Itoa(len(res.obj.Data)))
+		add(propGetLastModified, res.obj.Modified.UTC().Format(http.TimeFormat))
+		if withData {
+			add(propCalendarData, dav.Escape(res.obj.Data))
+		}
+	}
+	return l
+}
+
+// propResponse returns a response for a resource with the requested properties.
+func (s server) propResponse(acc *store.Account, res resource, names []xml.Name, nameOnly bool) *dav.Response {
+	available := s.properties(acc, res, slices.Contains(names, propCalendarData))
+	return dav.PropResponse(s.href(res), available, names, nameOnly)
+}
+
+// children returns the resources directly below res.
+func children(tx *bstore.Tx, res resource) ([]resource, error) {
+	switch res.kind {
+	case kindRoot:
+		return []resource{{kind: kindPrincipal}, {kind: kindHome}}, nil
+	case kindHome:
+		cals, err := bstore.QueryTx[store.Calendar](tx).SortAsc("ID").List()
+		if err != nil {
+			return nil, fmt.Errorf("listing calendars: %w", err)
+		}
+		var l []resource
+		for _, c := range cals {
+			l = append(l, resource{kind: kindCalendar, calName: c.Name, cal: &c})
+		}
+		return l, nil
+	case kindCalendar:
+		objs, err := bstore.QueryTx[store.CalendarObject](tx).FilterNonzero(store.CalendarObject{CalendarID: res.cal.ID}).SortAsc("ID").List()
+		if err != nil {
+			return nil, fmt.Errorf("listing calendar objects: %w", err)
+		}
+		var l []resource
+		for _, o := range objs {
+			l = append(l, resource{kind: kindObject, calName: res.calName, objName: o.Name, cal: res.cal, obj: &o})
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+func (s server) propfind(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	}
+
+	depth := r.Header.Get("Depth")
+	if depth != "0" && depth != "1" {
+		dav.WriteError(w, http.StatusForbidden, "<d:propfind-finite-depth/>")
+		return
+	}
+
+	var req dav.PropfindRequest
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing propfind request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var names []xml.Name
+	if req.AllProp == nil && req.PropName == nil && req.Prop != nil {
+		names = req.Prop.Names()
+	}
+
+	resources := []resource{res}
+	if depth == "1" {
+		err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+			l, err := children(tx, res)
+			resources = append(resources, l...)
+			return err
+		})
+		if err != nil {
+			log.Errorx("listing resources", err)
+			http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	var responses []*dav.Response
+	for _, cr := range resources {
+		responses = append(responses, s.propResponse(acc, cr, names, req.PropName != nil))
+	}
+	dav.WriteMultistatus(w, responses)
+}
+
+func (s server) proppatch(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	} else if res.kind != kindCalendar {
+		http.Error(w, "403 - forbidden - properties can only be changed on calendars", http.StatusForbidden)
+		return
+	}
+
+	var req dav.PropertyUpdate
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing proppatch request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := updateCalendar(ctx, acc, res.cal.ID, req, res.calName == inboxName || res.calName == outboxName)
+	if err != nil {
+		log.Errorx("updating calendar properties", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp.Href = s.href(res)
+	dav.WriteMultistatus(w, []*dav.Response{resp})
+}
+
+// updateCalendar applies the property changes to the calendar, either all or
+// none. The response has the status for each property.
+func updateCalendar(ctx context.Context, acc *store.Account, calID int64, req dav.PropertyUpdate, readonly bool) (*dav.Response, error) {
+	resp := &dav.Response{}
+	var failed bool
+	apply := func(c *store.Calendar, e dav.Element, remove bool) {
+		v := strings.TrimSpace(e.Text)
+		if remove {
+			v = ""
+		}
+		switch e.XMLName {
+		case propDisplayName:
+			c.DisplayName = v
+		case propCalendarDescription:
+			c.Description = v
+		case propCalendarColor:
+			c.Color = v
+		default:
+			resp.Add(http.StatusForbidden, dav.Prop{Name: e.XMLName})
+			failed = true
+			return
+		}
+		if readonly {
+			resp.Add(http.StatusForbidden, dav.Prop{Name: e.XMLName})
+			failed = true
+			return
+		}
+		resp.Add(http.StatusOK, dav.Prop{Name: e.XMLName})
+	}
+
+	var c store.Calendar
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		c = store.Calendar{ID: calID}
+		if err := tx.Get(&c); err != nil {
+			return fmt.Errorf("get calendar: %w", err)
+		}
+		for _, set := range req.Set {
+			for _, e := range set.Prop.Elements {
+				apply(&c, e, false)
+			}
+		}
+		for _, rm := range req.Remove {
+			for _, e := range rm.Prop.Elements {
+				apply(&c, e, true)
+			}
+		}
+		if failed {
+			return nil
+		}
+		c.ModSeq++
+		return tx.Update(&c)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		resp.FailOK()
+	}
+	return resp, nil
+}
+
+func (s server) mkcalendar(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if res.kind != kindCalendar {
+		http.Error(w, "403 - forbidden - calendars can only be created in the calendar home", http.StatusForbidden)
+		return
+	} else if res.exists() {
+		dav.WriteError(w, http.StatusMethodNotAllowed, "<d:resource-must-be-null/>")
+		return
+	} else if res.calName == inboxName || res.calName == outboxName || !dav.ValidName(res.calName) {
+		http.Error(w, "403 - forbidden - invalid calendar name", http.StatusForbidden)
+		return
+	}
+
+	var req dav.PropertyUpdate
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing mkcalendar request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Resource type for extended MKCOL (RFC 5689) is implied.
+	for i := range req.Set {
+		req.Set[i].Prop.Elements = slices.DeleteFunc(req.Set[i].Prop.Elements, func(e dav.Element) bool { return e.XMLName == propResourceType })
+	}
+
+	var cal store.Calendar
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		cal = store.Calendar{Name: res.calName}
+		return tx.Insert(&cal)
+	})
+	if err != nil && errors.Is(err, bstore.ErrUnique) {
+		dav.WriteError(w, http.StatusMethodNotAllowed, "<d:resource-must-be-null/>")
+		return
+	} else if err != nil {
+		log.Errorx("adding calendar", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp, err := updateCalendar(ctx, acc, cal.ID, req, false)
+	if err != nil {
+		log.Errorx("setting calendar properties", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(resp.Props[http.StatusForbidden]) > 0 {
+		// Calendar properties are not essential, log but keep the calendar.
+		log.Debug("unsupported properties for new calendar", slog.Any("properties", resp.Props[http.StatusForbidden]))
+	}
+	w.Header().Set("Location", s.href(res))
+	w.WriteHeader(http.StatusCreated)
+}
+
+// objectETag returns the etag of an object, or an empty string if it doesn't exist.
+func objectETag(obj *store.CalendarObject) string {
+	if obj == nil {
+		return ""
+	}
+	return obj.ETag
+}
+
+func (s server) get(w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	} else if res.kind != kindObject {
+		http.Error(w, "405 - method not allowed - only calendar objects can be fetched", http.StatusMethodNotAllowed)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/calendar; charset=utf-8")
+	h.Set("ETag", `"`+res.obj.ETag+`"`)
+	h.Set("Last-Modified", res.obj.Modified.UTC().Format(http.TimeFormat))
+	if r.Method == "HEAD" {
+		h.Set("Content-Length", strconv.Itoa(len(res.obj.Data)))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Write([]byte(res.obj.Data))
+}
+
+// objectUID returns the UID of the components of a calendar object. All
+// components except time zones must have the same UID.
+func objectUID(cal *ical.Component) (string, error) {
+	if cal.Name != "VCALENDAR" {
+		return "", fmt.Errorf("object is not a calendar")
+	}
+	var uid string
+	for _, c := range cal.Components {
+		if c.Name == "VTIMEZONE" {
+			continue
+		}
+		if u := c.Text("UID"); u == "" {
+			return "", fmt.Errorf("component %s without uid", c.Name)
+		} else if uid != "" && u != uid {
+			return "", fmt.Errorf("components with different uids")
+		} else {
+			uid = u
+		}
+	}
+	if uid == "" {
+		return "", fmt.Errorf("no components in calendar")
+	}
+	return uid, nil
+}
+
+func (s server) put(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if res.kind != kindObject || res.cal == nil {
+		http.Error(w, "409 - conflict - objects can only be stored in existing calendars", http.StatusConflict)
+		return
+	} else if res.calName == inboxName || res.calName == outboxName {
+		http.Error(w, "403 - forbidden - objects cannot be stored in scheduling inbox or outbox", http.StatusForbidden)
+		return
+	} else if !dav.ValidName(res.objName) {
+		http.Error(w, "403 - forbidden - invalid object name", http.StatusForbidden)
+		return
+	}
+
+	buf, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxObjectSize))
+	if err != nil {
+		dav.WriteError(w, http.StatusForbidden, "<c:max-resource-size/>")
+		return
+	}
+	data := string(buf)
+	cal, err := ical.Parse(strings.NewReader(data))
+	if err != nil {
+		log.Debugx("parsing calendar object", err)
+		dav.WriteError(w, http.StatusForbidden, "<c:valid-calendar-data/>")
+		return
+	}
+	uid, err := objectUID(cal)
+	if err != nil {
+		log.Debugx("checking calendar object", err)
+		dav.WriteError(w, http.StatusForbidden, "<c:valid-calendar-object-resource/>")
+		return
+	}
+
+	var created, failed bool
+	var conflict string
+	obj := store.CalendarObject{
+		CalendarID: res.cal.ID,
+		Name:       res.objName,
+		UID:        uid,
+		Modified:   time.Now(),
+		ETag:       store.ETag(data),
+		Data:       data,
+	}
+	err = acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		// Look up again, it may have changed since the request started.
+		if err := lookup(tx, &res); err != nil {
+			return err
+		}
+		if res.cal == nil {
+			return bstore.ErrAbsent
+		}
+		if !dav.CheckPreconditions(r, objectETag(res.obj)) {
+			failed = true
+			return nil
+		}
+
+		other, err := bstore.QueryTx[store.CalendarObject](tx).FilterNonzero(store.CalendarObject{CalendarID: res.cal.ID, UID: uid}).FilterNotEqual("Name", res.objName).Get()
+		if err == nil {
+			conflict = s.href(resource{kind: kindObject, calName: res.calName, objName: other.Name})
+			return nil
+		} else if err != bstore.ErrAbsent {
+			return fmt.Errorf("checking for uid conflict: %w", err)
+		}
+
+		if res.obj == nil {
+			created = true
+			err = tx.Insert(&obj)
+		} else {
+			obj.ID = res.obj.ID
+			err = tx.Update(&obj)
+		}
+		if err != nil {
+			return fmt.Errorf("storing calendar object: %w", err)
+		}
+		res.cal.ModSeq++
+		return tx.Update(res.cal)
+	})
+	if err == bstore.ErrAbsent {
+		http.Error(w, "409 - conflict - calendar removed", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Errorx("storing calendar object", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	} else if failed {
+		http.Error(w, "412 - precondition failed", http.StatusPreconditionFailed)
+		return
+	} else if conflict != "" {
+		dav.WriteError(w, http.StatusForbidden, "<c:no-uid-conflict><d:href>"+dav.Escape(conflict)+"</d:href></c:no-uid-conflict>")
+		return
+	}
+
+	log.Debug("calendar object stored", slog.String("uid", uid), slog.Bool("created", created))
+	w.Header().Set("ETag", `"`+obj.ETag+`"`)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s server) delete(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	} else if res.kind == kindCalendar && (res.calName == inboxName || res.calName == outboxName) {
+		http.Error(w, "403 - forbidden - scheduling inbox and outbox cannot be removed", http.StatusForbidden)
+		return
+	} else if res.kind != kindCalendar && res.kind != kindObject {
+		http.Error(w, "403 - forbidden - only calendars and objects can be removed", http.StatusForbidden)
+		return
+	}
+
+	var failed bool
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		if err := lookup(tx, &res); err != nil {
+			return err
+		}
+		if !res.exists() {
+			return bstore.ErrAbsent
+		}
+
+		if res.kind == kindCalendar {
+			_, err := bstore.QueryTx[store.CalendarObject](tx).FilterNonzero(store.CalendarObject{CalendarID: res.cal.ID}).Delete()
+			if err != nil {
+				return fmt.Errorf("removing calendar objects: %w", err)
+			}
+			return tx.Delete(res.cal)
+		}
+
+		if !dav.CheckPreconditions(r, objectETag(res.obj)) {
+			failed = true
+			return nil
+		}
+		if err := tx.Delete(res.obj); err != nil {
+			return fmt.Errorf("removing calendar object: %w", err)
+		}
+		res.cal.ModSeq++
+		return tx.Update(res.cal)
+	})
+	if err == bstore.ErrAbsent {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Errorx("removing resource", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	} else if failed {
+		http.Error(w, "412 - precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type timeRange struct {
+	Start string `xml:"start,attr"`
+	End   string `xml:"end,attr"`
+}
+
+type compFilter struct {
+	Name       string       `xml:"name,attr"`
+	TimeRange  *timeRange   `xml:"urn:ietf:params:xml:ns:caldav time-range"`
+	CompFilter []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+type filter struct {
+	CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// For calendar-query and calendar-multiget reports.
+type reportRequest struct {
+	XMLName xml.Name
+	AllProp *struct{}     `xml:"DAV: allprop"`
+	Prop    *dav.PropList `xml:"DAV: prop"`
+	Hrefs   []string      `xml:"DAV: href"`
+	Filter  *filter       `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+func (s server) report(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req reportRequest
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing report request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.XMLName.Space != dav.NSCalDAV || req.XMLName.Local != "calendar-multiget" && req.XMLName.Local != "calendar-query" {
+		dav.WriteError(w, http.StatusForbidden, "<d:supported-report/>")
+		return
+	} else if res.kind != kindCalendar && res.kind != kindObject {
+		dav.WriteError(w, http.StatusForbidden, "<d:supported-report/>")
+		return
+	}
+	var names []xml.Name
+	if req.AllProp == nil && req.Prop != nil {
+		names = req.Prop.Names()
+	}
+
+	var responses []*dav.Response
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		if req.XMLName.Local == "calendar-multiget" {
+			for _, href := range req.Hrefs {
+				href = strings.TrimSpace(href)
+				p, ok := strings.CutPrefix(href, s.path)
+				if !ok {
+					// Hrefs can be full URLs.
+					if i := strings.Index(href, s.path); i >= 0 && strings.Contains(href[:i], "://") {
+						p, ok = href[i+len(s.path):], true
+					}
+				}
+				cr, ok2 := parsePath("/" + p)
+				if !ok || !ok2 || cr.kind != kindObject || cr.calName != res.calName {
+					responses = append(responses, &dav.Response{Href: href, Status: http.StatusNotFound})
+					continue
+				}
+				if err := lookup(tx, &cr); err != nil {
+					return err
+				}
+				if cr.obj == nil {
+					responses = append(responses, &dav.Response{Href: href, Status: http.StatusNotFound})
+					continue
+				}
+				responses = append(responses, s.propResponse(acc, cr, names, false))
+			}
+			return nil
+		}
+
+		l := []resource{res}
+		if res.kind == kindCalendar {
+			var err error
+			l, err = children(tx, res)
+			if err != nil {
+				return err
+			}
+		}
+		for _, cr := range l {
+			if req.Filter != nil && !matchObject(log, cr.obj.Data, req.Filter.CompFilter) {
+				continue
+			}
+			responses = append(responses, s.propResponse(acc, cr, names, false))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorx("report", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	dav.WriteMultistatus(w, responses)
+}
+
+// matchObject returns whether calendar data matches a filter of a
+// calendar-query. Objects that cannot be parsed match.
+func matchObject(log mlog.Log, data string, cf compFilter) bool {
+	cal, err := ical.Parse(strings.NewReader(data))
+	if err != nil {
+		log.Debugx("parsing calendar object for filter", err)
+		return true
+	}
+	return matchComponent(cal, cf)
+}
+
+func matchComponent(c *ical.Component, cf compFilter) bool {
+	if !strings.EqualFold(c.Name, cf.Name) {
+		return false
+	}
+	if cf.TimeRange != nil && !overlaps(c, *cf.TimeRange) {
+		return false
+	}
+	for _, sub := range cf.CompFilter {
+		if !slices.ContainsFunc(c.Components, func(sc ical.Component) bool { return matchComponent(&sc, sub) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// overlaps returns whether a component overlaps with a time range. Components with
+// recurrence rules, or without start time, always match.
+func overlaps(c *ical.Component, tr timeRange) bool {
+	if c.Property("RRULE") != nil || c.Property("RDATE") != nil {
+		return true
+	}
+	start := c.Property("DTSTART")
+	if start == nil {
+		return true
+	}
+	st, allDay, err := start.Time()
+	if err != nil {
+		return true
+	}
+	et := st
+	if allDay {
+		et = st.AddDate(0, 0, 1)
+	}
+	for _, name := range []string{"DTEND", "DUE"} {
+		if p := c.Property(name); p != nil {
+			if t, _, err := p.Time(); err == nil {
+				et = t
+			}
+			break
+		}
+	}
+	if tr.Start != "" {
+		if t, err := time.Parse("20060102T150405Z", tr.Start); err == nil && !et.After(t) && !(et.Equal(st) && st.Equal(t)) {
+			return false
+		}
+	}
+	if tr.End != "" {
+		if t, err := time.Parse("20060102T150405Z", tr.End); err == nil && !st.Before(t) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,218 @@
+package caldav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, expect any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got:\n%#v\nexpected:\n%#v", got, expect)
+	}
+}
+
+const event = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"PRODID:-//Example//Test//EN\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:event1@mox.example\r\n" +
+	"SEQUENCE:0\r\n" +
+	"DTSTART:20240102T140000Z\r\n" +
+	"DTEND:20240102T150000Z\r\n" +
+	"SUMMARY:Meeting\r\n" +
+	"ORGANIZER:mailto:organizer@remote.example\r\n" +
+	"ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:mjl@mox.example\r\n" +
+	"ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:other@remote.example\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestServer(t *testing.T) {
+	mox.LimitersInit()
+	os.RemoveAll("../testdata/caldav/data")
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/caldav/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	log := mlog.New("caldav", nil)
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		tcheck(t, err, "account close")
+		acc.WaitClosed()
+	}()
+	err = acc.SetPassword(log, "test1234")
+	tcheck(t, err, "set password")
+
+	handler := http.StripPrefix("/caldav", NewServer("/caldav/", false))
+
+	do := func(method, path, body string, hdrs map[string]string, expCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("mjl@mox.example", "test1234")
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if rw.Code != expCode {
+			t.Fatalf("%s %s: got status %d, expected %d, body %q", method, path, rw.Code, expCode, rw.Body.String())
+		}
+		return rw
+	}
+	contains := func(rw *httptest.ResponseRecorder, l ...string) {
+		t.Helper()
+		for _, s := range l {
+			if !strings.Contains(rw.Body.String(), s) {
+				t.Fatalf("response does not contain %q: %s", s, rw.Body.String())
+			}
+		}
+	}
+	depth0 := map[string]string{"Depth": "0"}
+	depth1 := map[string]string{"Depth": "1"}
+
+	// Bad credentials.
+	req := httptest.NewRequest("PROPFIND", "/caldav/", nil)
+	req.SetBasicAuth("mjl@mox.example", "bad")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	tcompare(t, rw.Code, http.StatusUnauthorized)
+
+	rw = do("OPTIONS", "/caldav/", "", nil, http.StatusOK)
+	tcompare(t, rw.Header().Get("DAV"), "1, calendar-access")
+
+	// Discovery, like clients do.
+	rw = do("PROPFIND", "/caldav/", `<propfind xmlns="DAV:"><prop><current-user-principal/><x:unknown xmlns:x="urn:x"/></prop></propfind>`, depth0, http.StatusMultiStatus)
+	contains(rw, "<d:current-user-principal><d:href>/caldav/principal/</d:href></d:current-user-principal>", "<x:unknown xmlns:x=\"urn:x\"/>", "404 Not Found")
+	rw = do("PROPFIND", "/caldav/principal/", `<propfind xmlns="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><prop><c:calendar-home-set/><c:calendar-user-address-set/><c:schedule-inbox-URL/></prop></propfind>`, depth0, http.StatusMultiStatus)
+	contains(rw, "<d:href>/caldav/calendars/</d:href>", "<d:href>mailto:mjl@mox.example</d:href>", "<d:href>/caldav/calendars/inbox/</d:href>")
+	rw = do("PROPFIND", "/caldav/calendars/", "", depth1, http.StatusMultiStatus)
+	contains(rw, "/caldav/calendars/default/", "<c:schedule-inbox/>", "<c:schedule-outbox/>", "<c:calendar/>")
+
+	do("PROPFIND", "/caldav/calendars/", "", nil, http.StatusForbidden)
+	do("PROPFIND", "/caldav/calendars/missing/", "", depth0, http.StatusNotFound)
+
+	// Create calendar, change properties.
+	do("MKCALENDAR", "/caldav/calendars/work/", `<c:mkcalendar xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:set><d:prop><d:displayname>Work</d:displayname></d:prop></d:set></c:mkcalendar>`, nil, http.StatusCreated)
+	do("MKCALENDAR", "/caldav/calendars/work/", "", nil, http.StatusMethodNotAllowed)
+	do("MKCALENDAR", "/caldav/calendars/inbox/", "", nil, http.StatusMethodNotAllowed)
+	rw = do("PROPPATCH", "/caldav/calendars/work/", `<propertyupdate xmlns="DAV:"><set><prop><x:calendar-color xmlns:x="http://apple.com/ns/ical/">#00ff00</x:calendar-color><x:other xmlns:x="urn:x">v</x:other></prop></set></propertyupdate>`, nil, http.StatusMultiStatus)
+	contains(rw, "424 Failed Dependency", "403 Forbidden")
+	do("PROPPATCH", "/caldav/calendars/work/", `<propertyupdate xmlns="DAV:"><set><prop><x:calendar-color xmlns:x="http://apple.com/ns/ical/">#ff0000</x:calendar-color></prop></set></propertyupdate>`, nil, http.StatusMultiStatus)
+	rw = do("PROPFIND", "/caldav/calendars/work/", `<propfind xmlns="DAV:"><prop><displayname/><x:calendar-color xmlns:x="http://apple.com/ns/ical/"/><x:getctag xmlns:x="http://calendarserver.org/ns/"/></prop></propfind>`, depth0, http.StatusMultiStatus)
+	contains(rw, "<d:displayname>Work</d:displayname>", "<ic:calendar-color>#ff0000</ic:calendar-color>", "<cs:getctag>2</cs:getctag>")
+
+	// Store event.
+	do("PUT", "/caldav/calendars/work/event1.ics", "bogus", nil, http.StatusForbidden)
+	do("PUT", "/caldav/calendars/missing/event1.ics", event, nil, http.StatusConflict)
+	do("PUT", "/caldav/calendars/inbox/event1.ics", event, nil, http.StatusForbidden)
+	rw = do("PUT", "/caldav/calendars/work/event1.ics", event, map[string]string{"If-None-Match": "*"}, http.StatusCreated)
+	etag := rw.Header().Get("ETag")
+	do("PUT", "/caldav/calendars/work/event1.ics", event, map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed)
+	do("PUT", "/caldav/calendars/work/event1.ics", event, map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed)
+	do("PUT", "/caldav/calendars/work/event1.ics", event, map[string]string{"If-Match": etag}, http.StatusNoContent)
+	rw = do("PUT", "/caldav/calendars/work/event2.ics", event, nil, http.StatusForbidden)
+	contains(rw, "no-uid-conflict")
+
+	rw = do("GET", "/caldav/calendars/work/event1.ics", "", nil, http.StatusOK)
+	tcompare(t, rw.Body.String(), event)
+	tcompare(t, rw.Header().Get("ETag"), etag)
+
+	// Reports.
+	rw = do("REPORT", "/caldav/calendars/work/", `<c:calendar-multiget xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/><c:calendar-data/></d:prop><d:href>/caldav/calendars/work/event1.ics</d:href><d:href>/caldav/calendars/work/missing.ics</d:href></c:calendar-multiget>`, depth1, http.StatusMultiStatus)
+	contains(rw, "SUMMARY:Meeting", "<d:status>HTTP/1.1 404 Not Found</d:status>")
+	query := func(start, end string) string {
+		return `<c:calendar-query xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav"><d:prop><d:getetag/></d:prop><c:filter><c:comp-filter name="VCALENDAR"><c:comp-filter name="VEVENT"><c:time-range start="` + start + `" end="` + end + `"/></c:comp-filter></c:comp-filter></c:filter></c:calendar-query>`
+	}
+	rw = do("REPORT", "/caldav/calendars/work/", query("20240101T000000Z", "20240103T000000Z"), depth1, http.StatusMultiStatus)
+	contains(rw, "event1.ics")
+	rw = do("REPORT", "/caldav/calendars/work/", query("20240103T000000Z", "20240104T000000Z"), depth1, http.StatusMultiStatus)
+	if strings.Contains(rw.Body.String(), "event1.ics") {
+		t.Fatalf("event outside time range in response: %s", rw.Body.String())
+	}
+
+	// Deliver scheduling messages. The reply from a validated attendee updates the event.
+	var msgID int64
+	deliver := func(method, from, ev string, validated bool) {
+		t.Helper()
+		ical := strings.Replace(ev, "PRODID:-//Example//Test//EN\r\n", "PRODID:-//Example//Test//EN\r\nMETHOD:"+method+"\r\n", 1)
+		msg := strings.ReplaceAll("From: <"+from+">\nTo: <mjl@mox.example>\nSubject: invite\nMIME-Version: 1.0\nContent-Type: text/calendar; method="+method+"\n\n", "\n", "\r\n") + ical
+		part, err := message.Parse(log.Logger, false, strings.NewReader(msg))
+		tcheck(t, err, "parse message")
+		err = part.Walk(log.Logger, nil)
+		tcheck(t, err, "walk message")
+		localpart, domain, _ := strings.Cut(from, "@")
+		msgID++
+		m := store.Message{ID: msgID, MsgFromLocalpart: smtp.Localpart(localpart), MsgFromDomain: domain, MsgFromValidated: validated}
+		err = Deliver(log, acc, m, part)
+		tcheck(t, err, "deliver")
+	}
+	reply := strings.Replace(event, "ATTENDEE;PARTSTAT=NEEDS-ACTION:mailto:other@remote.example", "ATTENDEE;PARTSTAT=ACCEPTED:mailto:other@remote.example", 1)
+	deliver("REPLY", "other@remote.example", reply, false)
+	rw = do("GET", "/caldav/calendars/work/event1.ics", "", nil, http.StatusOK)
+	tcompare(t, rw.Body.String(), event)
+	deliver("REPLY", "other@remote.example", reply, true)
+	rw = do("GET", "/caldav/calendars/work/event1.ics", "", nil, http.StatusOK)
+	contains(rw, "ATTENDEE;PARTSTAT=ACCEPTED:mailto:other@remote.example")
+	if rw.Header().Get("ETag") == etag {
+		t.Fatalf("etag not changed after update")
+	}
+
+	// Cancellation from someone other than the organizer is ignored.
+	deliver("CANCEL", "other@remote.example", event, true)
+	rw = do("GET", "/caldav/calendars/work/event1.ics", "", nil, http.StatusOK)
+	if strings.Contains(rw.Body.String(), "CANCELLED") {
+		t.Fatalf("event cancelled by attendee")
+	}
+	deliver("CANCEL", "organizer@remote.example", event, true)
+	rw = do("GET", "/caldav/calendars/work/event1.ics", "", nil, http.StatusOK)
+	contains(rw, "STATUS:CANCELLED")
+
+	// Scheduling messages are in the inbox.
+	rw = do("PROPFIND", "/caldav/calendars/inbox/", "", depth1, http.StatusMultiStatus)
+	contains(rw, "/caldav/calendars/inbox/1.ics", "/caldav/calendars/inbox/4.ics")
+
+	// Remove.
+	do("DELETE", "/caldav/calendars/inbox/", "", nil, http.StatusForbidden)
+	do("DELETE", "/caldav/calendars/work/event1.ics", "", map[string]string{"If-Match": etag}, http.StatusPreconditionFailed)
+	do("DELETE", "/caldav/calendars/work/event1.ics", "", nil, http.StatusNoContent)
+	do("GET", "/caldav/calendars/work/event1.ics", "", nil, http.StatusNotFound)
+	do("DELETE", "/caldav/calendars/work/", "", nil, http.StatusNoContent)
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		n, err := bstore.QueryTx[store.Calendar](tx).Count()
+		tcompare(t, n, 3)
+		return err
+	})
+	tcheck(t, err, "count calendars")
+}
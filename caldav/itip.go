@@ -0,0 +1,253 @@
+package caldav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/ical"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+// Deliver processes an iTIP scheduling message (RFC 5546) in a message delivered
+// to the account over email (RFC 6047). The calendar data of the first
+// text/calendar part is added to the scheduling inbox. If the message is from a
+// validated sender, changes are applied to an existing event in one of the
+// calendars of the account with the same UID: updates (REQUEST) and cancellations
+// (CANCEL) from the organizer, and replies (REPLY) from attendees. Other messages
+// and messages marked as junk are ignored.
+func Deliver(log mlog.Log, acc *store.Account, m store.Message, part message.Part) error {
+	if m.Junk {
+		return nil
+	}
+	p := calendarPart(&part)
+	if p == nil {
+		return nil
+	}
+	buf, err := io.ReadAll(io.LimitReader(p.ReaderUTF8OrBinary(), maxObjectSize+1))
+	if err != nil {
+		return fmt.Errorf("reading calendar part: %w", err)
+	} else if len(buf) > maxObjectSize {
+		log.Debug("calendar part too large, ignoring")
+		return nil
+	}
+	data := string(buf)
+	cal, err := ical.Parse(strings.NewReader(data))
+	if err != nil {
+		log.Debugx("parsing calendar part, ignoring", err)
+		return nil
+	}
+	method := strings.ToUpper(cal.Text("METHOD"))
+	uid, err := objectUID(cal)
+	if method == "" || err != nil {
+		log.Debugx("calendar part is not a scheduling message, ignoring", err, slog.String("method", method))
+		return nil
+	}
+	from := string(m.MsgFromLocalpart) + "@" + m.MsgFromDomain
+	log = log.With(slog.String("method", method), slog.String("uid", uid), slog.String("from", from))
+
+	ctx := context.Background()
+	if err := ensureCalendars(ctx, acc); err != nil {
+		return err
+	}
+	return acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		inbox, err := bstore.QueryTx[store.Calendar](tx).FilterNonzero(store.Calendar{Name: inboxName}).Get()
+		if err != nil {
+			return fmt.Errorf("get scheduling inbox: %w", err)
+		}
+		obj := store.CalendarObject{
+			CalendarID: inbox.ID,
+			Name:       fmt.Sprintf("%d.ics", m.ID),
+			UID:        uid,
+			Modified:   time.Now(),
+			ETag:       store.ETag(data),
+			Data:       data,
+		}
+		if err := tx.Insert(&obj); err != nil && !errors.Is(err, bstore.ErrUnique) {
+			return fmt.Errorf("adding to scheduling inbox: %w", err)
+		}
+		inbox.ModSeq++
+		if err := tx.Update(&inbox); err != nil {
+			return fmt.Errorf("updating scheduling inbox: %w", err)
+		}
+		log.Debug("scheduling message added to inbox")
+
+		if !m.MsgFromValidated {
+			return nil
+		}
+
+		objs, err := bstore.QueryTx[store.CalendarObject](tx).FilterNonzero(store.CalendarObject{UID: uid}).FilterNotEqual("CalendarID", inbox.ID).List()
+		if err != nil {
+			return fmt.Errorf("looking up existing events: %w", err)
+		}
+		for _, o := range objs {
+			ecal, err := ical.Parse(strings.NewReader(o.Data))
+			if err != nil {
+				log.Debugx("parsing existing calendar object, not updating", err, slog.String("name", o.Name))
+				continue
+			}
+			if !applyScheduling(ecal, cal, method, from) {
+				continue
+			}
+			o.Data = string(ecal.Marshal())
+			o.ETag = store.ETag(o.Data)
+			o.Modified = time.Now()
+			if err := tx.Update(&o); err != nil {
+				return fmt.Errorf("updating calendar object: %w", err)
+			}
+			c := store.Calendar{ID: o.CalendarID}
+			if err := tx.Get(&c); err != nil {
+				return fmt.Errorf("get calendar: %w", err)
+			}
+			c.ModSeq++
+			if err := tx.Update(&c); err != nil {
+				return fmt.Errorf("updating calendar: %w", err)
+			}
+			log.Info("applied scheduling message to calendar object", slog.String("calendar", c.Name), slog.String("name", o.Name))
+		}
+		return nil
+	})
+}
+
+// calendarPart returns the first text/calendar part.
+func calendarPart(p *message.Part) *message.Part {
+	if p.MediaType == "TEXT" && p.MediaSubType == "CALENDAR" {
+		return p
+	}
+	for i := range p.Parts {
+		if cp := calendarPart(&p.Parts[i]); cp != nil {
+			return cp
+		}
+	}
+	return nil
+}
+
+// mailtoAddress returns the email address of a "mailto:" URI value, or an empty
+// string.
+func mailtoAddress(p *ical.Property) string {
+	if p == nil || len(p.Value) < len("mailto:") || !strings.EqualFold(p.Value[:len("mailto:")], "mailto:") {
+		return ""
+	}
+	return p.Value[len("mailto:"):]
+}
+
+// sequence returns the SEQUENCE of a component, 0 if absent.
+func sequence(c *ical.Component) int {
+	v, _ := strconv.Atoi(c.Text("SEQUENCE"))
+	return v
+}
+
+// recurrenceID returns the RECURRENCE-ID of a component, an empty string for the
+// main component.
+func recurrenceID(c *ical.Component) string {
+	if p := c.Property("RECURRENCE-ID"); p != nil {
+		return p.Value
+	}
+	return ""
+}
+
+// setProperty replaces the value of the first property with name, or adds the
+// property.
+func setProperty(c *ical.Component, name, value string) {
+	if p := c.Property(name); p != nil {
+		p.Value = value
+		p.Params = nil
+		return
+	}
+	c.Properties = append(c.Properties, ical.Property{Name: name, Value: value})
+}
+
+// applyScheduling applies an iTIP message with method from sender to an existing
+// calendar object. It returns whether the object was changed.
+func applyScheduling(existing, msg *ical.Component, method, sender string) bool {
+	var changed bool
+	for _, mc := range msg.Components {
+		if mc.Name == "VTIMEZONE" {
+			continue
+		}
+		rid := recurrenceID(&mc)
+		i := slices.IndexFunc(existing.Components, func(ec ical.Component) bool {
+			return ec.Name == mc.Name && recurrenceID(&ec) == rid
+		})
+
+		switch method {
+		case "REQUEST":
+			// Only the organizer can update an event.
+			if !strings.EqualFold(mailtoAddress(mc.Property("ORGANIZER")), sender) {
+				continue
+			}
+			if i < 0 {
+				// New instance of a recurring event.
+				if !slices.ContainsFunc(existing.Components, func(ec ical.Component) bool {
+					return ec.Name == mc.Name && recurrenceID(&ec) == "" && strings.EqualFold(mailtoAddress(ec.Property("ORGANIZER")), sender)
+				}) {
+					continue
+				}
+				existing.Components = append(existing.Components, mc)
+			} else if ec := &existing.Components[i]; !strings.EqualFold(mailtoAddress(ec.Property("ORGANIZER")), sender) || sequence(&mc) < sequence(ec) {
+				continue
+			} else {
+				*ec = mc
+			}
+			changed = true
+
+		case "CANCEL":
+			for j := range existing.Components {
+				ec := &existing.Components[j]
+				if ec.Name != mc.Name || (rid != "" && recurrenceID(ec) != rid) || !strings.EqualFold(mailtoAddress(ec.Property("ORGANIZER")), sender) {
+					continue
+				}
+				setProperty(ec, "STATUS", "CANCELLED")
+				changed = true
+			}
+
+		case "REPLY":
+			if i < 0 {
+				continue
+			}
+			ec := &existing.Components[i]
+			for _, ap := range mc.Properties {
+				if ap.Name != "ATTENDEE" || !strings.EqualFold(mailtoAddress(&ap), sender) {
+					continue
+				}
+				status := ap.Param("PARTSTAT")
+				if status == "" {
+					continue
+				}
+				for k := range ec.Properties {
+					ep := &ec.Properties[k]
+					if ep.Name == "ATTENDEE" && strings.EqualFold(mailtoAddress(ep), sender) {
+						if ep.Params == nil {
+							ep.Params = map[string][]string{}
+						}
+						ep.Params["PARTSTAT"] = []string{status}
+						changed = true
+					}
+				}
+			}
+		}
+	}
+	if changed && method == "REQUEST" {
+		// Add time zones referenced by the updated components.
+		for _, mc := range msg.Components {
+			if mc.Name != "VTIMEZONE" {
+				continue
+			}
+			tzid := mc.Text("TZID")
+			if !slices.ContainsFunc(existing.Components, func(ec ical.Component) bool { return ec.Name == "VTIMEZONE" && ec.Text("TZID") == tzid }) {
+				existing.Components = append([]ical.Component{mc}, existing.Components...)
+			}
+		}
+	}
+	return changed
+}
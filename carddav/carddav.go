@@ -0,0 +1,867 @@
+// Package carddav implements a CardDAV server, for synchronizing the address
+// books of an account with clients like DAVx5 and Apple Contacts.
+//
+// Address books and their contacts (vCards) are stored in the account database.
+// Recipient addresses of messages sent by the account are collected in the
+// address book named "collected", see store.ContactCollectName.
+//
+// URLs are relative to the configured path, typically /carddav/:
+//
+//	principal/ - Principal of the account.
+//	addressbooks/ - Home with address books.
+//	addressbooks/<name>/ - Address book.
+//	addressbooks/<name>/<contact> - Contact resource.
+//
+// See RFC 4918 for WebDAV, RFC 6352 for CardDAV and RFC 6350 for vCard.
+package carddav
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dav"
+	"github.com/mjl-/mox/ical"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+var pkglog = mlog.New("carddav", nil)
+
+var (
+	metricRequests = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_carddav_requests_total",
+			Help: "CardDAV requests by method and HTTP response status code.",
+		},
+		[]string{"method", "code"},
+	)
+)
+
+// Address book created for accounts without address books.
+const defaultName = "default"
+
+// Maximum size of a contact.
+const maxContactSize = 1024 * 1024
+
+// NewServer returns a CardDAV HTTP handler. Path is the path the handler is
+// configured under, with trailing slash, used in hrefs in responses. Requests
+// must have the path stripped. If isForwarded is set, the remote IP for rate
+// limiting authentication failures is taken from the X-Forwarded-For header.
+func NewServer(path string, isForwarded bool) http.Handler {
+	return server{path, isForwarded}
+}
+
+type server struct {
+	path        string
+	isForwarded bool
+}
+
+// kind of resource a URL path refers to.
+type kind int
+
+const (
+	kindRoot kind = iota
+	kindPrincipal
+	kindHome
+	kindAddressBook
+	kindContact
+)
+
+// resource is the target of a request, or a child in a PROPFIND response.
+type resource struct {
+	kind        kind
+	bookName    string             // For kindAddressBook and kindContact.
+	contactName string             // For kindContact.
+	book        *store.AddressBook // If address book exists.
+	contact     *store.Contact     // If contact exists.
+}
+
+// parsePath parses a path with the configured path already stripped.
+func parsePath(p string) (resource, bool) {
+	t := strings.Split(strings.Trim(p, "/"), "/")
+	switch {
+	case len(t) == 1 && t[0] == "":
+		return resource{kind: kindRoot}, true
+	case len(t) == 1 && t[0] == "principal":
+		return resource{kind: kindPrincipal}, true
+	case len(t) == 1 && t[0] == "addressbooks":
+		return resource{kind: kindHome}, true
+	case len(t) == 2 && t[0] == "addressbooks" && t[1] != "":
+		return resource{kind: kindAddressBook, bookName: t[1]}, true
+	case len(t) == 3 && t[0] == "addressbooks" && t[1] != "" && t[2] != "" && !strings.HasSuffix(p, "/"):
+		return resource{kind: kindContact, bookName: t[1], contactName: t[2]}, true
+	}
+	return resource{}, false
+}
+
+// href returns the absolute path of a resource, as used in responses.
+func (s server) href(res resource) string {
+	switch res.kind {
+	case kindRoot:
+		return s.path
+	case kindPrincipal:
+		return s.path + "principal/"
+	case kindHome:
+		return s.path + "addressbooks/"
+	case kindAddressBook:
+		return s.path + "addressbooks/" + res.bookName + "/"
+	case kindContact:
+		return s.path + "addressbooks/" + res.bookName + "/" + res.contactName
+	}
+	panic("missing case")
+}
+
+// ServeHTTP implements http.Handler.
+func (s server) ServeHTTP(xw http.ResponseWriter, r *http.Request) {
+	log := pkglog.WithContext(r.Context()).With(slog.String("method", r.Method), slog.String("path", r.URL.Path))
+
+	w := &dav.StatusWriter{ResponseWriter: xw}
+	defer func() {
+		metricRequests.WithLabelValues(r.Method, strconv.Itoa(w.Status)).Inc()
+	}()
+
+	h := w.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Cache-Control", "no-cache, max-age=0")
+
+	acc := dav.Authenticate(log, w, r, "carddav", s.isForwarded)
+	if acc == nil {
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	log = log.With(slog.String("account", acc.Name))
+
+	ctx := r.Context()
+	if err := ensureAddressBook(ctx, acc); err != nil {
+		log.Errorx("ensuring address book for account", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	res, ok := parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if res.kind == kindAddressBook || res.kind == kindContact {
+		err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+			return lookup(tx, &res)
+		})
+		if err != nil {
+			log.Errorx("looking up resource", err)
+			http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		h.Set("DAV", "1, addressbook")
+		h.Set("Allow", "OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, PROPPATCH, REPORT, MKCOL")
+		w.WriteHeader(http.StatusOK)
+	case "PROPFIND":
+		s.propfind(ctx, log, acc, w, r, res)
+	case "PROPPATCH":
+		s.proppatch(ctx, log, acc, w, r, res)
+	case "REPORT":
+		s.report(ctx, log, acc, w, r, res)
+	case "GET", "HEAD":
+		s.get(w, r, res)
+	case "PUT":
+		s.put(ctx, log, acc, w, r, res)
+	case "DELETE":
+		s.delete(ctx, log, acc, w, r, res)
+	case "MKCOL":
+		s.mkcol(ctx, log, acc, w, r, res)
+	default:
+		http.Error(w, "405 - method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// ensureAddressBook adds a default address book if the account has none, not
+// counting the address book with collected addresses.
+func ensureAddressBook(ctx context.Context, acc *store.Account) error {
+	var exists bool
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		var err error
+		exists, err = bstore.QueryTx[store.AddressBook](tx).FilterNotEqual("Name", store.ContactCollectName).Exists()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("looking up address books: %w", err)
+	} else if exists {
+		return nil
+	}
+	return acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		ab := store.AddressBook{Name: defaultName, DisplayName: "Contacts"}
+		err := tx.Insert(&ab)
+		if err != nil && !errors.Is(err, bstore.ErrUnique) {
+			return fmt.Errorf("adding address book: %w", err)
+		}
+		return nil
+	})
+}
+
+// lookup sets the address book and contact of res, if they exist.
+func lookup(tx *bstore.Tx, res *resource) error {
+	ab, err := bstore.QueryTx[store.AddressBook](tx).FilterNonzero(store.AddressBook{Name: res.bookName}).Get()
+	if err == bstore.ErrAbsent {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("looking up address book: %w", err)
+	}
+	res.book = &ab
+	if res.kind != kindContact {
+		return nil
+	}
+	c, err := bstore.QueryTx[store.Contact](tx).FilterNonzero(store.Contact{AddressBookID: ab.ID, Name: res.contactName}).Get()
+	if err == bstore.ErrAbsent {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("looking up contact: %w", err)
+	}
+	res.contact = &c
+	return nil
+}
+
+// exists returns whether the resource exists.
+func (res resource) exists() bool {
+	switch res.kind {
+	case kindAddressBook:
+		return res.book != nil
+	case kindContact:
+		return res.contact != nil
+	}
+	return true
+}
+
+// contactETag returns the etag of a contact, or an empty string if it doesn't
+// exist.
+func contactETag(c *store.Contact) string {
+	if c == nil {
+		return ""
+	}
+	return c.ETag
+}
+
+// Names of properties.
+func cardDAVName(local string) xml.Name { return xml.Name{Space: dav.NSCardDAV, Local: local} }
+
+var (
+	propResourceType         = dav.DAVName("resourcetype")
+	propDisplayName          = dav.DAVName("displayname")
+	propCurrentUserPrincipal = dav.DAVName("current-user-principal")
+	propPrivilegeSet         = dav.DAVName("current-user-privilege-set")
+	propPrincipalURL         = dav.DAVName("principal-URL")
+	propSupportedReportSet   = dav.DAVName("supported-report-set")
+	propGetETag              = dav.DAVName("getetag")
+	propGetContentType       = dav.DAVName("getcontenttype")
+	propGetContentLength     = dav.DAVName("getcontentlength")
+	propGetLastModified      = dav.DAVName("getlastmodified")
+	propAddressBookHomeSet   = cardDAVName("addressbook-home-set")
+	propAddressBookDesc      = cardDAVName("addressbook-description")
+	propSupportedAddressData = cardDAVName("supported-address-data")
+	propMaxResourceSize      = cardDAVName("max-resource-size")
+	propAddressData          = cardDAVName("address-data")
+	propGetCTag              = xml.Name{Space: dav.NSCS, Local: "getctag"}
+)
+
+// properties returns the properties of an existing resource, for allprop and
+// for looking up requested properties. The vCard data of contacts is only
+// included if withData is set.
+func (s server) properties(acc *store.Account, res resource, withData bool) []dav.Prop {
+	var l []dav.Prop
+	add := func(name xml.Name, value string) {
+		l = append(l, dav.Prop{Name: name, Value: value})
+	}
+	add(propCurrentUserPrincipal, dav.Href(s.href(resource{kind: kindPrincipal})))
+	add(propPrivilegeSet, "<d:privilege><d:read/></d:privilege><d:privilege><d:write/></d:privilege><d:privilege><d:write-properties/></d:privilege><d:privilege><d:write-content/></d:privilege><d:privilege><d:bind/></d:privilege><d:privilege><d:unbind/></d:privilege>")
+
+	switch res.kind {
+	case kindRoot:
+		add(propResourceType, "<d:collection/>")
+	case kindPrincipal:
+		conf, _ := acc.Conf()
+		displayName := conf.FullName
+		if displayName == "" {
+			displayName = acc.Name
+		}
+		add(propResourceType, "<d:collection/><d:principal/>")
+		add(propDisplayName, dav.Escape(displayName))
+		add(propPrincipalURL, dav.Href(s.href(res)))
+		add(propAddressBookHomeSet, dav.Href(s.href(resource{kind: kindHome})))
+	case kindHome:
+		add(propResourceType, "<d:collection/>")
+		add(propDisplayName, "Address books")
+	case kindAddressBook:
+		displayName := res.book.DisplayName
+		if displayName == "" {
+			displayName = res.book.Name
+		}
+		add(propResourceType, "<d:collection/><card:addressbook/>")
+		add(propDisplayName, dav.Escape(displayName))
+		add(propGetCTag, strconv.FormatInt(res.book.ModSeq, 10))
+		add(propAddressBookDesc, dav.Escape(res.book.Description))
+		add(propSupportedAddressData, `<card:address-data-type content-type="text/vcard" version="3.0"/><card:address-data-type content-type="text/vcard" version="4.0"/>`)
+		add(propMaxResourceSize, strconv.Itoa(maxContactSize))
+		add(propSupportedReportSet, `<d:supported-report><d:report><card:addressbook-multiget/></d:report></d:supported-report><d:supported-report><d:report><card:addressbook-query/></d:report></d:supported-report>`)
+	case kindContact:
+		add(propResourceType, "")
+		add(propGetETag, dav.Escape(`"`+res.contact.ETag+`"`))
+		add(propGetContentType, "text/vcard; charset=utf-8")
+		add(propGetContentLength, strconv.Itoa(len(res.contact.Data)))
+		add(propGetLastModified, res.contact.Modified.UTC().Format(http.TimeFormat))
+		if withData {
+			add(propAddressData, dav.Escape(res.contact.Data))
+		}
+	}
+	return l
+}
+
+// propResponse returns a response for a resource with the requested properties.
+func (s server) propResponse(acc *store.Account, res resource, names []xml.Name, nameOnly bool) *dav.Response {
+	available := s.properties(acc, res, slices.Contains(names, propAddressData))
+	return dav.PropResponse(s.href(res), available, names, nameOnly)
+}
+
+// children returns the resources directly below res.
+func children(tx *bstore.Tx, res resource) ([]resource, error) {
+	switch res.kind {
+	case kindRoot:
+		return []resource{{kind: kindPrincipal}, {kind: kindHome}}, nil
+	case kindHome:
+		books, err := bstore.QueryTx[store.AddressBook](tx).SortAsc("ID").List()
+		if err != nil {
+			return nil, fmt.Errorf("listing address books: %w", err)
+		}
+		var l []resource
+		for _, ab := range books {
+			l = append(l, resource{kind: kindAddressBook, bookName: ab.Name, book: &ab})
+		}
+		return l, nil
+	case kindAddressBook:
+		contacts, err := bstore.QueryTx[store.Contact](tx).FilterNonzero(store.Contact{AddressBookID: res.book.ID}).SortAsc("ID").List()
+		if err != nil {
+			return nil, fmt.Errorf("listing contacts: %w", err)
+		}
+		var l []resource
+		for _, c := range contacts {
+			l = append(l, resource{kind: kindContact, bookName: res.bookName, contactName: c.Name, book: res.book, contact: &c})
+		}
+		return l, nil
+	}
+	return nil, nil
+}
+
+func (s server) propfind(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	}
+
+	depth := r.Header.Get("Depth")
+	if depth != "0" && depth != "1" {
+		dav.WriteError(w, http.StatusForbidden, "<d:propfind-finite-depth/>")
+		return
+	}
+
+	var req dav.PropfindRequest
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing propfind request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	var names []xml.Name
+	if req.AllProp == nil && req.PropName == nil && req.Prop != nil {
+		names = req.Prop.Names()
+	}
+
+	resources := []resource{res}
+	if depth == "1" {
+		err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+			l, err := children(tx, res)
+			resources = append(resources, l...)
+			return err
+		})
+		if err != nil {
+			log.Errorx("listing resources", err)
+			http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+			return
+		}
+	}
+	var responses []*dav.Response
+	for _, cr := range resources {
+		responses = append(responses, s.propResponse(acc, cr, names, req.PropName != nil))
+	}
+	dav.WriteMultistatus(w, responses)
+}
+
+func (s server) proppatch(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	} else if res.kind != kindAddressBook {
+		http.Error(w, "403 - forbidden - properties can only be changed on address books", http.StatusForbidden)
+		return
+	}
+
+	var req dav.PropertyUpdate
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing proppatch request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := updateAddressBook(ctx, acc, res.book.ID, req)
+	if err != nil {
+		log.Errorx("updating address book properties", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp.Href = s.href(res)
+	dav.WriteMultistatus(w, []*dav.Response{resp})
+}
+
+// updateAddressBook applies the property changes to the address book, either all
+// or none. The response has the status for each property.
+func updateAddressBook(ctx context.Context, acc *store.Account, bookID int64, req dav.PropertyUpdate) (*dav.Response, error) {
+	resp := &dav.Response{}
+	var failed bool
+	apply := func(ab *store.AddressBook, e dav.Element, remove bool) {
+		v := strings.TrimSpace(e.Text)
+		if remove {
+			v = ""
+		}
+		switch e.XMLName {
+		case propDisplayName:
+			ab.DisplayName = v
+		case propAddressBookDesc:
+			ab.Description = v
+		default:
+			resp.Add(http.StatusForbidden, dav.Prop{Name: e.XMLName})
+			failed = true
+			return
+		}
+		resp.Add(http.StatusOK, dav.Prop{Name: e.XMLName})
+	}
+
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		ab := store.AddressBook{ID: bookID}
+		if err := tx.Get(&ab); err != nil {
+			return fmt.Errorf("get address book: %w", err)
+		}
+		for _, set := range req.Set {
+			for _, e := range set.Prop.Elements {
+				apply(&ab, e, false)
+			}
+		}
+		for _, rm := range req.Remove {
+			for _, e := range rm.Prop.Elements {
+				apply(&ab, e, true)
+			}
+		}
+		if failed {
+			return nil
+		}
+		ab.ModSeq++
+		return tx.Update(&ab)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if failed {
+		resp.FailOK()
+	}
+	return resp, nil
+}
+
+// mkcol creates an address book with an extended MKCOL request, RFC 5689.
+func (s server) mkcol(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if res.kind != kindAddressBook {
+		http.Error(w, "403 - forbidden - address books can only be created in the address book home", http.StatusForbidden)
+		return
+	} else if res.exists() {
+		dav.WriteError(w, http.StatusMethodNotAllowed, "<d:resource-must-be-null/>")
+		return
+	} else if !dav.ValidName(res.bookName) {
+		http.Error(w, "403 - forbidden - invalid address book name", http.StatusForbidden)
+		return
+	}
+
+	var req dav.PropertyUpdate
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing mkcol request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Only address books can be created, the resource type is implied.
+	for i := range req.Set {
+		req.Set[i].Prop.Elements = slices.DeleteFunc(req.Set[i].Prop.Elements, func(e dav.Element) bool { return e.XMLName == propResourceType })
+	}
+
+	var ab store.AddressBook
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		ab = store.AddressBook{Name: res.bookName}
+		return tx.Insert(&ab)
+	})
+	if err != nil && errors.Is(err, bstore.ErrUnique) {
+		dav.WriteError(w, http.StatusMethodNotAllowed, "<d:resource-must-be-null/>")
+		return
+	} else if err != nil {
+		log.Errorx("adding address book", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	resp, err := updateAddressBook(ctx, acc, ab.ID, req)
+	if err != nil {
+		log.Errorx("setting address book properties", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	if len(resp.Props[http.StatusForbidden]) > 0 {
+		// Address book properties are not essential, log but keep the address book.
+		log.Debug("unsupported properties for new address book", slog.Any("properties", resp.Props[http.StatusForbidden]))
+	}
+	w.Header().Set("Location", s.href(res))
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s server) get(w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	} else if res.kind != kindContact {
+		http.Error(w, "405 - method not allowed - only contacts can be fetched", http.StatusMethodNotAllowed)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/vcard; charset=utf-8")
+	h.Set("ETag", `"`+res.contact.ETag+`"`)
+	h.Set("Last-Modified", res.contact.Modified.UTC().Format(http.TimeFormat))
+	if r.Method == "HEAD" {
+		h.Set("Content-Length", strconv.Itoa(len(res.contact.Data)))
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Write([]byte(res.contact.Data))
+}
+
+func (s server) put(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if res.kind != kindContact || res.book == nil {
+		http.Error(w, "409 - conflict - contacts can only be stored in existing address books", http.StatusConflict)
+		return
+	} else if !dav.ValidName(res.contactName) {
+		http.Error(w, "403 - forbidden - invalid contact name", http.StatusForbidden)
+		return
+	}
+
+	buf, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxContactSize))
+	if err != nil {
+		dav.WriteError(w, http.StatusForbidden, "<card:max-resource-size/>")
+		return
+	}
+	data := string(buf)
+	uid, fullName, emails, err := store.ContactParse(data)
+	if err != nil {
+		log.Debugx("parsing contact", err)
+		dav.WriteError(w, http.StatusForbidden, "<card:valid-address-data/>")
+		return
+	}
+
+	var created, failed bool
+	var conflict string
+	c := store.Contact{
+		AddressBookID: res.book.ID,
+		Name:          res.contactName,
+		UID:           uid,
+		Modified:      time.Now(),
+		ETag:          store.ETag(data),
+		Data:          data,
+		FullName:      fullName,
+		Emails:        emails,
+	}
+	err = acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		// Look up again, it may have changed since the request started.
+		if err := lookup(tx, &res); err != nil {
+			return err
+		}
+		if res.book == nil {
+			return bstore.ErrAbsent
+		}
+		if !dav.CheckPreconditions(r, contactETag(res.contact)) {
+			failed = true
+			return nil
+		}
+
+		other, err := bstore.QueryTx[store.Contact](tx).FilterNonzero(store.Contact{AddressBookID: res.book.ID, UID: uid}).FilterNotEqual("Name", res.contactName).Get()
+		if err == nil {
+			conflict = s.href(resource{kind: kindContact, bookName: res.bookName, contactName: other.Name})
+			return nil
+		} else if err != bstore.ErrAbsent {
+			return fmt.Errorf("checking for uid conflict: %w", err)
+		}
+
+		if res.contact == nil {
+			created = true
+			err = tx.Insert(&c)
+		} else {
+			c.ID = res.contact.ID
+			err = tx.Update(&c)
+		}
+		if err != nil {
+			return fmt.Errorf("storing contact: %w", err)
+		}
+		res.book.ModSeq++
+		return tx.Update(res.book)
+	})
+	if err == bstore.ErrAbsent {
+		http.Error(w, "409 - conflict - address book removed", http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Errorx("storing contact", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	} else if failed {
+		http.Error(w, "412 - precondition failed", http.StatusPreconditionFailed)
+		return
+	} else if conflict != "" {
+		dav.WriteError(w, http.StatusForbidden, "<card:no-uid-conflict>"+dav.Href(conflict)+"</card:no-uid-conflict>")
+		return
+	}
+
+	log.Debug("contact stored", slog.String("uid", uid), slog.Bool("created", created))
+	w.Header().Set("ETag", `"`+c.ETag+`"`)
+	if created {
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (s server) delete(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	} else if res.kind != kindAddressBook && res.kind != kindContact {
+		http.Error(w, "403 - forbidden - only address books and contacts can be removed", http.StatusForbidden)
+		return
+	}
+
+	var failed bool
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		if err := lookup(tx, &res); err != nil {
+			return err
+		}
+		if !res.exists() {
+			return bstore.ErrAbsent
+		}
+
+		if res.kind == kindAddressBook {
+			_, err := bstore.QueryTx[store.Contact](tx).FilterNonzero(store.Contact{AddressBookID: res.book.ID}).Delete()
+			if err != nil {
+				return fmt.Errorf("removing contacts: %w", err)
+			}
+			return tx.Delete(res.book)
+		}
+
+		if !dav.CheckPreconditions(r, contactETag(res.contact)) {
+			failed = true
+			return nil
+		}
+		if err := tx.Delete(res.contact); err != nil {
+			return fmt.Errorf("removing contact: %w", err)
+		}
+		res.book.ModSeq++
+		return tx.Update(res.book)
+	})
+	if err == bstore.ErrAbsent {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		log.Errorx("removing resource", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	} else if failed {
+		http.Error(w, "412 - precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type textMatch struct {
+	Text      string `xml:",chardata"`
+	MatchType string `xml:"match-type,attr"` // equals, contains (default), starts-with, ends-with.
+	Negate    string `xml:"negate-condition,attr"`
+}
+
+type propFilter struct {
+	Name         string      `xml:"name,attr"`
+	Test         string      `xml:"test,attr"` // anyof (default) or allof.
+	IsNotDefined *struct{}   `xml:"urn:ietf:params:xml:ns:carddav is-not-defined"`
+	TextMatches  []textMatch `xml:"urn:ietf:params:xml:ns:carddav text-match"`
+}
+
+type filter struct {
+	Test        string       `xml:"test,attr"` // anyof (default) or allof.
+	PropFilters []propFilter `xml:"urn:ietf:params:xml:ns:carddav prop-filter"`
+}
+
+// For addressbook-query and addressbook-multiget reports.
+type reportRequest struct {
+	XMLName xml.Name
+	AllProp *struct{}     `xml:"DAV: allprop"`
+	Prop    *dav.PropList `xml:"DAV: prop"`
+	Hrefs   []string      `xml:"DAV: href"`
+	Filter  *filter       `xml:"urn:ietf:params:xml:ns:carddav filter"`
+}
+
+func (s server) report(ctx context.Context, log mlog.Log, acc *store.Account, w http.ResponseWriter, r *http.Request, res resource) {
+	if !res.exists() {
+		http.NotFound(w, r)
+		return
+	}
+
+	var req reportRequest
+	if err := dav.ReadXML(r, &req); err != nil {
+		log.Debugx("parsing report request", err)
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.XMLName.Space != dav.NSCardDAV || req.XMLName.Local != "addressbook-multiget" && req.XMLName.Local != "addressbook-query" {
+		dav.WriteError(w, http.StatusForbidden, "<d:supported-report/>")
+		return
+	} else if res.kind != kindAddressBook && res.kind != kindContact {
+		dav.WriteError(w, http.StatusForbidden, "<d:supported-report/>")
+		return
+	}
+	var names []xml.Name
+	if req.AllProp == nil && req.Prop != nil {
+		names = req.Prop.Names()
+	}
+
+	var responses []*dav.Response
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		if req.XMLName.Local == "addressbook-multiget" {
+			for _, href := range req.Hrefs {
+				href = strings.TrimSpace(href)
+				p, ok := strings.CutPrefix(href, s.path)
+				if !ok {
+					// Hrefs can be full URLs.
+					if i := strings.Index(href, s.path); i >= 0 && strings.Contains(href[:i], "://") {
+						p, ok = href[i+len(s.path):], true
+					}
+				}
+				cr, ok2 := parsePath("/" + p)
+				if !ok || !ok2 || cr.kind != kindContact || cr.bookName != res.bookName {
+					responses = append(responses, &dav.Response{Href: href, Status: http.StatusNotFound})
+					continue
+				}
+				if err := lookup(tx, &cr); err != nil {
+					return err
+				}
+				if cr.contact == nil {
+					responses = append(responses, &dav.Response{Href: href, Status: http.StatusNotFound})
+					continue
+				}
+				responses = append(responses, s.propResponse(acc, cr, names, false))
+			}
+			return nil
+		}
+
+		l := []resource{res}
+		if res.kind == kindAddressBook {
+			var err error
+			l, err = children(tx, res)
+			if err != nil {
+				return err
+			}
+		}
+		for _, cr := range l {
+			if req.Filter != nil && !matchContact(log, cr.contact.Data, *req.Filter) {
+				continue
+			}
+			responses = append(responses, s.propResponse(acc, cr, names, false))
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorx("report", err)
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	dav.WriteMultistatus(w, responses)
+}
+
+// matchContact returns whether vCard data matches the filter of an
+// addressbook-query. Contacts that cannot be parsed match.
+func matchContact(log mlog.Log, data string, f filter) bool {
+	card, err := ical.Parse(strings.NewReader(data))
+	if err != nil {
+		log.Debugx("parsing contact for filter", err)
+		return true
+	}
+	if len(f.PropFilters) == 0 {
+		return true
+	}
+	allOf := strings.EqualFold(f.Test, "allof")
+	for _, pf := range f.PropFilters {
+		if matchPropFilter(card, pf) != allOf {
+			return !allOf
+		}
+	}
+	return allOf
+}
+
+func matchPropFilter(card *ical.Component, pf propFilter) bool {
+	var values []string
+	for _, p := range card.Properties {
+		if strings.EqualFold(p.Name, pf.Name) {
+			values = append(values, strings.ToLower(p.Text()))
+		}
+	}
+	if pf.IsNotDefined != nil {
+		return len(values) == 0
+	} else if len(pf.TextMatches) == 0 {
+		return len(values) > 0
+	}
+
+	allOf := strings.EqualFold(pf.Test, "allof")
+	for _, tm := range pf.TextMatches {
+		text := strings.ToLower(tm.Text)
+		match := slices.ContainsFunc(values, func(v string) bool {
+			switch tm.MatchType {
+			case "equals":
+				return v == text
+			case "starts-with":
+				return strings.HasPrefix(v, text)
+			case "ends-with":
+				return strings.HasSuffix(v, text)
+			default:
+				return strings.Contains(v, text)
+			}
+		})
+		if tm.Negate == "yes" {
+			match = !match
+		}
+		if match != allOf {
+			return !allOf
+		}
+	}
+	return allOf
+}
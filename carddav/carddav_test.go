@@ -0,0 +1,204 @@
+package carddav
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, expect any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got:\n%#v\nexpected:\n%#v", got, expect)
+	}
+}
+
+const card = "BEGIN:VCARD\r\n" +
+	"VERSION:3.0\r\n" +
+	"UID:contact1\r\n" +
+	"FN:Jane Doe\r\n" +
+	"EMAIL;TYPE=INTERNET:Jane@Remote.example\r\n" +
+	"END:VCARD\r\n"
+
+func TestServer(t *testing.T) {
+	mox.LimitersInit()
+	os.RemoveAll("../testdata/carddav/data")
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/carddav/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	log := mlog.New("carddav", nil)
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		tcheck(t, err, "account close")
+		acc.WaitClosed()
+	}()
+	err = acc.SetPassword(log, "test1234")
+	tcheck(t, err, "set password")
+
+	handler := http.StripPrefix("/carddav", NewServer("/carddav/", false))
+
+	do := func(method, path, body string, hdrs map[string]string, expCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.SetBasicAuth("mjl@mox.example", "test1234")
+		for k, v := range hdrs {
+			req.Header.Set(k, v)
+		}
+		rw := httptest.NewRecorder()
+		handler.ServeHTTP(rw, req)
+		if rw.Code != expCode {
+			t.Fatalf("%s %s: got status %d, expected %d, body %q", method, path, rw.Code, expCode, rw.Body.String())
+		}
+		return rw
+	}
+	contains := func(rw *httptest.ResponseRecorder, l ...string) {
+		t.Helper()
+		for _, s := range l {
+			if !strings.Contains(rw.Body.String(), s) {
+				t.Fatalf("response does not contain %q: %s", s, rw.Body.String())
+			}
+		}
+	}
+	notContains := func(rw *httptest.ResponseRecorder, s string) {
+		t.Helper()
+		if strings.Contains(rw.Body.String(), s) {
+			t.Fatalf("response unexpectedly contains %q: %s", s, rw.Body.String())
+		}
+	}
+	depth0 := map[string]string{"Depth": "0"}
+	depth1 := map[string]string{"Depth": "1"}
+
+	// Bad credentials.
+	req := httptest.NewRequest("PROPFIND", "/carddav/", nil)
+	req.SetBasicAuth("mjl@mox.example", "bad")
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+	tcompare(t, rw.Code, http.StatusUnauthorized)
+
+	rw = do("OPTIONS", "/carddav/", "", nil, http.StatusOK)
+	tcompare(t, rw.Header().Get("DAV"), "1, addressbook")
+
+	// Discovery, like clients do.
+	rw = do("PROPFIND", "/carddav/", `<propfind xmlns="DAV:"><prop><current-user-principal/></prop></propfind>`, depth0, http.StatusMultiStatus)
+	contains(rw, "<d:current-user-principal><d:href>/carddav/principal/</d:href></d:current-user-principal>")
+	rw = do("PROPFIND", "/carddav/principal/", `<propfind xmlns="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><prop><card:addressbook-home-set/></prop></propfind>`, depth0, http.StatusMultiStatus)
+	contains(rw, "<d:href>/carddav/addressbooks/</d:href>")
+	rw = do("PROPFIND", "/carddav/addressbooks/", "", depth1, http.StatusMultiStatus)
+	contains(rw, "/carddav/addressbooks/default/", "<card:addressbook/>", "<d:displayname>Contacts</d:displayname>")
+
+	do("PROPFIND", "/carddav/addressbooks/", "", nil, http.StatusForbidden)
+	do("PROPFIND", "/carddav/addressbooks/missing/", "", depth0, http.StatusNotFound)
+
+	// Create address book, change properties.
+	do("MKCOL", "/carddav/addressbooks/work/", `<mkcol xmlns="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><set><prop><resourcetype><collection/><card:addressbook/></resourcetype><displayname>Work</displayname></prop></set></mkcol>`, nil, http.StatusCreated)
+	do("MKCOL", "/carddav/addressbooks/work/", "", nil, http.StatusMethodNotAllowed)
+	rw = do("PROPPATCH", "/carddav/addressbooks/work/", `<propertyupdate xmlns="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><set><prop><card:addressbook-description>Colleagues</card:addressbook-description><x:other xmlns:x="urn:x">v</x:other></prop></set></propertyupdate>`, nil, http.StatusMultiStatus)
+	contains(rw, "424 Failed Dependency", "403 Forbidden")
+	do("PROPPATCH", "/carddav/addressbooks/work/", `<propertyupdate xmlns="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><set><prop><card:addressbook-description>Colleagues</card:addressbook-description></prop></set></propertyupdate>`, nil, http.StatusMultiStatus)
+	rw = do("PROPFIND", "/carddav/addressbooks/work/", `<propfind xmlns="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><prop><displayname/><card:addressbook-description/><x:getctag xmlns:x="http://calendarserver.org/ns/"/></prop></propfind>`, depth0, http.StatusMultiStatus)
+	contains(rw, "<d:displayname>Work</d:displayname>", "<card:addressbook-description>Colleagues</card:addressbook-description>", "<cs:getctag>2</cs:getctag>")
+
+	// Store contact.
+	do("PUT", "/carddav/addressbooks/work/contact1.vcf", "bogus", nil, http.StatusForbidden)
+	do("PUT", "/carddav/addressbooks/missing/contact1.vcf", card, nil, http.StatusConflict)
+	rw = do("PUT", "/carddav/addressbooks/work/contact1.vcf", card, map[string]string{"If-None-Match": "*"}, http.StatusCreated)
+	etag := rw.Header().Get("ETag")
+	do("PUT", "/carddav/addressbooks/work/contact1.vcf", card, map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed)
+	do("PUT", "/carddav/addressbooks/work/contact1.vcf", card, map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed)
+	rw = do("PUT", "/carddav/addressbooks/work/other.vcf", card, nil, http.StatusForbidden)
+	contains(rw, "<card:no-uid-conflict><d:href>/carddav/addressbooks/work/contact1.vcf</d:href></card:no-uid-conflict>")
+
+	rw = do("GET", "/carddav/addressbooks/work/contact1.vcf", "", nil, http.StatusOK)
+	tcompare(t, rw.Body.String(), card)
+	tcompare(t, rw.Header().Get("ETag"), etag)
+
+	// Reports.
+	rw = do("REPORT", "/carddav/addressbooks/work/", `<card:addressbook-multiget xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><d:prop><d:getetag/><card:address-data/></d:prop><d:href>/carddav/addressbooks/work/contact1.vcf</d:href><d:href>/carddav/addressbooks/work/missing.vcf</d:href></card:addressbook-multiget>`, depth1, http.StatusMultiStatus)
+	contains(rw, "UID:contact1", "404 Not Found", "<d:getetag>&#34;")
+	rw = do("REPORT", "/carddav/addressbooks/work/", `<card:addressbook-query xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><d:prop><d:getetag/></d:prop><card:filter><card:prop-filter name="EMAIL"><card:text-match match-type="starts-with">jane@</card:text-match></card:prop-filter></card:filter></card:addressbook-query>`, depth1, http.StatusMultiStatus)
+	contains(rw, "/carddav/addressbooks/work/contact1.vcf")
+	rw = do("REPORT", "/carddav/addressbooks/work/", `<card:addressbook-query xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><d:prop><d:getetag/></d:prop><card:filter test="allof"><card:prop-filter name="FN"><card:text-match>jane</card:text-match></card:prop-filter><card:prop-filter name="NICKNAME"><card:is-not-defined/></card:prop-filter><card:prop-filter name="EMAIL"><card:text-match negate-condition="yes">remote</card:text-match></card:prop-filter></card:filter></card:addressbook-query>`, depth1, http.StatusMultiStatus)
+	notContains(rw, "contact1.vcf")
+
+	// Contact fields used for autocomplete.
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		c, err := bstore.QueryTx[store.Contact](tx).FilterNonzero(store.Contact{UID: "contact1"}).Get()
+		tcheck(t, err, "get contact")
+		tcompare(t, c.FullName, "Jane Doe")
+		tcompare(t, c.Emails, []string{"jane@remote.example"})
+		return nil
+	})
+	tcheck(t, err, "read")
+
+	// Recipients of sent messages are collected after the second message.
+	send := func() {
+		t.Helper()
+		msgFile, err := store.CreateMessageTemp(log, "carddav-test")
+		tcheck(t, err, "create temp message file")
+		defer store.CloseRemoveTempFile(log, msgFile, "temp message file")
+		const msg = "From: <mjl@mox.example>\r\nTo: Bob <bob@remote.example>\r\nSubject: test\r\n\r\ntest\r\n"
+		_, err = msgFile.Write([]byte(msg))
+		tcheck(t, err, "write message")
+		p, err := message.EnsurePart(log.Logger, false, msgFile, int64(len(msg)))
+		tcheck(t, err, "parse message")
+		acc.WithWLock(func() {
+			err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+				mb, err := bstore.QueryTx[store.Mailbox](tx).FilterNonzero(store.Mailbox{Name: "Sent"}).Get()
+				tcheck(t, err, "sent mailbox")
+				m := store.Message{MailboxID: mb.ID, MailboxOrigID: mb.ID, Received: time.Now(), Size: int64(len(msg))}
+				m.ParsedBuf, err = json.Marshal(p)
+				tcheck(t, err, "marshal part")
+				err = acc.MessageAdd(log, tx, &mb, &m, msgFile, store.AddOpts{SkipSourceFileSync: true, SkipDirSync: true})
+				tcheck(t, err, "add message")
+				return tx.Update(&mb)
+			})
+			tcheck(t, err, "add sent message")
+		})
+	}
+	send()
+	rw = do("PROPFIND", "/carddav/addressbooks/", "", depth1, http.StatusMultiStatus)
+	notContains(rw, "/carddav/addressbooks/collected/")
+	send()
+	rw = do("REPORT", "/carddav/addressbooks/collected/", `<card:addressbook-query xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav"><d:prop><card:address-data/></d:prop></card:addressbook-query>`, depth1, http.StatusMultiStatus)
+	contains(rw, "FN:Bob", "bob@remote.example")
+
+	// Delete.
+	do("DELETE", "/carddav/addressbooks/work/contact1.vcf", "", map[string]string{"If-Match": `"other"`}, http.StatusPreconditionFailed)
+	do("DELETE", "/carddav/addressbooks/work/contact1.vcf", "", map[string]string{"If-Match": etag}, http.StatusNoContent)
+	do("GET", "/carddav/addressbooks/work/contact1.vcf", "", nil, http.StatusNotFound)
+	do("DELETE", "/carddav/addressbooks/work/", "", nil, http.StatusNoContent)
+	do("PROPFIND", "/carddav/addressbooks/work/", "", depth0, http.StatusNotFound)
+}
@@ -69,10 +69,40 @@ type Static struct {
 	DefaultMailboxes []string             `sconf:"optional" sconf-doc:"Deprecated in favor of InitialMailboxes. Mailboxes to create when adding an account. Inbox is always created. If no mailboxes are specified, the following are automatically created: Sent, Archive, Trash, Drafts and Junk."`
 	Transports       map[string]Transport `sconf:"optional" sconf-doc:"Transport are mechanisms for delivering messages. Transports can be referenced from Routes in accounts, domains and the global configuration. There is always an implicit/fallback delivery transport doing direct delivery with SMTP from the outgoing message queue. Transports are typically only configured when using smarthosts, i.e. when delivering through another SMTP server. Zero or one transport methods must be set in a transport, never multiple. When using an external party to send email for a domain, keep in mind you may have to add their IP address to your domain's SPF record, and possibly additional DKIM records."`
 	// Awkward naming of fields to get intended default behaviour for zero values.
-	NoOutgoingDMARCReports          bool  `sconf:"optional" sconf-doc:"Do not send DMARC reports (aggregate only). By default, aggregate reports on DMARC evaluations are sent to domains if their DMARC policy requests them. Reports are sent at whole hours, with a minimum of 1 hour and maximum of 24 hours, rounded up so a whole number of intervals cover 24 hours, aligned at whole days in UTC. Reports are sent from the postmaster@<mailhostname> address."`
-	NoOutgoingTLSReports            bool  `sconf:"optional" sconf-doc:"Do not send TLS reports. By default, reports about failed SMTP STARTTLS connections and related MTA-STS/DANE policies are sent to domains if their TLSRPT DNS record requests them. Reports covering a 24 hour UTC interval are sent daily. Reports are sent from the postmaster address of the configured domain the mailhostname is in. If there is no such domain, or it does not have DKIM configured, no reports are sent."`
-	OutgoingTLSReportsForAllSuccess bool  `sconf:"optional" sconf-doc:"Also send TLS reports if there were no SMTP STARTTLS connection failures. By default, reports are only sent when at least one failure occurred. If a report is sent, it does always include the successful connection counts as well."`
-	QuotaMessageSize                int64 `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for each individual account, only applicable if greater than zero. Can be overridden per account. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage. The quota only applies to the email message files, not to any file system overhead and also not the message index database file (account for approximately 15% overhead)."`
+	NoOutgoingDMARCReports          bool                  `sconf:"optional" sconf-doc:"Do not send DMARC reports (aggregate only). By default, aggregate reports on DMARC evaluations are sent to domains if their DMARC policy requests them. Reports are sent at whole hours, with a minimum of 1 hour and maximum of 24 hours, rounded up so a whole number of intervals cover 24 hours, aligned at whole days in UTC. Reports are sent from the postmaster@<mailhostname> address."`
+	NoOutgoingTLSReports            bool                  `sconf:"optional" sconf-doc:"Do not send TLS reports. By default, reports about failed SMTP STARTTLS connections and related MTA-STS/DANE policies are sent to domains if their TLSRPT DNS record requests them. Reports covering a 24 hour UTC interval are sent daily. Reports are sent from the postmaster address of the configured domain the mailhostname is in. If there is no such domain, or it does not have DKIM configured, no reports are sent."`
+	OutgoingTLSReportsForAllSuccess bool                  `sconf:"optional" sconf-doc:"Also send TLS reports if there were no SMTP STARTTLS connection failures. By default, reports are only sent when at least one failure occurred. If a report is sent, it does always include the successful connection counts as well."`
+	OutgoingTLSReportsHTTPS         bool                  `sconf:"optional" sconf-doc:"Also send TLS reports to https URIs in the rua field of TLSRPT records, with an HTTP POST of the gzipped JSON report. By default, only mailto URIs are used: reports sent over HTTPS are not DKIM-signed so cannot be authenticated, and receivers may ignore them."`
+	QuotaMessageSize                int64                 `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for each individual account, only applicable if greater than zero. Can be overridden per account. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage. The quota only applies to the email message files, not to any file system overhead and also not the message index database file (account for approximately 15% overhead)."`
+	QuotaSoftPercent                int                   `sconf:"optional" sconf-doc:"If non-zero, percentage of the maximum total message size of an account (see QuotaMessageSize) that is the soft limit. Accounts can temporarily go over the soft limit, during a grace period. Once the grace period has ended, new messages are rejected until the account is below the soft limit again. The maximum total message size is always enforced."`
+	QuotaGracePeriod                time.Duration         `sconf:"optional" sconf-doc:"Period during which an account can be over its soft limit, see QuotaSoftPercent. Default 7 days."`
+	QuotaWarnPercentages            []int                 `sconf:"optional" sconf-doc:"Percentages of the maximum total message size of an account at which a warning message is delivered to the Inbox of the account. When the lowest percentage is reached, IMAP logins also get an alert and the webmail shows a warning. Default 80, 90 and 95."`
+	QueueDeadLetterPeriod           time.Duration         `sconf:"optional" sconf-doc:"If non-zero, messages for which delivery from the queue failed permanently (including after exhausting all delivery attempts) are kept in a dead-letter queue for this period, with their message file. A DSN is still delivered to the sender. Messages in the dead-letter queue can be inspected, exported, have their recipient changed and be requeued for delivery by the admin. Useful for recovering from mistyped recipient domains and remote outages longer than the retry schedule. Reports (DMARC, TLS) are never kept. E.g. 720h (30 days)."`
+	PostmasterTools                 *PostmasterTools      `sconf:"optional" sconf-doc:"If set, reputation data about our outgoing email is periodically (daily) fetched from the configured mailbox providers, and made available in the admin web interface alongside the volume of our outgoing deliveries to those providers."`
+	SpamFilter                      *SpamFilter           `sconf:"optional" sconf-doc:"If set, incoming messages that are evaluated for their content, i.e. from senders without conclusive reputation, are also checked by an external spam filter, rspamd or SpamAssassin's spamd. The verdict is combined with the Bayesian junk filter of the account according to Policy. X-Spam-Status, X-Spam-Score, X-Spam-Flag and X-Spam-Symbols headers are added to delivered messages. If the external filter fails, e.g. because it is not running, messages are evaluated without it."`
+	ContentReputation               *ContentReputation    `sconf:"optional" sconf-doc:"If set, URLs and attachments of incoming messages that are evaluated for their content, i.e. from senders without conclusive reputation, are checked against local lists and DNS-based block lists. A listed URL domain or attachment hash rejects the message, or delivers it to the Junk mailbox if Quarantine is set."`
+	OutgoingAnomalies               *OutgoingAnomalies    `sconf:"optional" sconf-doc:"If set, messages submitted by accounts, over SMTP, the webmail and the webapi, are checked for anomalies that indicate a compromised account: a sudden spike in the number of messages, and a high rate of recipients for which delivery failed, e.g. unknown recipients. Depending on Action, sending is throttled or suspended, and an alert is delivered to the postmaster. Optionally, submissions from networks or at hours not seen before for an account also cause an alert. Compromised accounts are a common cause for mail servers getting listed on block lists."`
+	MessageCompression              *MessageCompression   `sconf:"optional" sconf-doc:"If set, new message files of accounts are stored compressed, and message files stored before compression was enabled are compressed in the background after startup. Messages are decompressed transparently when read, e.g. for IMAP FETCH, exports and the webmail. Messages are compressed with DEFLATE, in independently compressed chunks so parts of messages can be read without decompressing the whole message. Quota and message sizes are about the uncompressed messages. The admin web interface shows the uncompressed and stored sizes of an account."`
+	SearchIndexHashed               bool                  `sconf:"optional" sconf-doc:"If set, the full-text search index of accounts stores salted hashes of words instead of the words, so the index does not hold message text as is. The webmail hashes search words before sending them, so they aren't sent to the server either. This is not protection against anyone who can read the account database: the salt is stored in the same database and sent to webmail clients, so hashes of likely words, e.g. from a dictionary, can be computed and compared against the index. Trade-offs: Only whole words can be searched for, no substrings or phrases (each word of a phrase is matched separately), words are matched as the index was built without verifying against the message, search is only possible when the index of the account is complete, and IMAP searches don't use the index. Changing this setting rebuilds the indexes of accounts, in the background, when they are opened."`
+	MessageDeduplication            *MessageDeduplication `sconf:"optional" sconf-doc:"If set, message files with identical contents, e.g. for a message delivered to multiple local recipients, to mailing list subscribers, or imported multiple times, are stored once, keyed by a hash of their contents, and hard linked into the accounts. The number of hard links to a stored file is its reference count: files in the deduplication store that are no longer referenced by any account are removed periodically. Message prefixes with per-recipient headers are stored in the database, so message files are often identical across recipients. Quota and message sizes are not affected. Not available on Windows."`
+	OIDC                            *OIDC                 `sconf:"optional" sconf-doc:"If set, authentication can be delegated to an OpenID Connect identity provider, e.g. for single sign-on within an organization. The account and webmail web interfaces offer logging in through the provider, and IMAP and SMTP submission accept OAuth 2.0 access tokens issued by the provider with SASL mechanisms OAUTHBEARER and XOAUTH2, so mail clients don't have to store passwords. Access tokens are verified with the token introspection endpoint of the provider, and must have the client ID of mox as audience, or be issued to the client ID of mox. Users are matched to accounts by email address. Second factors are left to the identity provider, password policies and two-factor authentication configured in mox don't apply to these logins."`
+
+	OutgoingDMARCFailureReports *DMARCFailureReports `sconf:"optional" sconf-doc:"If set, DMARC failure reports are sent about incoming messages that fail DMARC, to domains that request them with \"ruf\" in their DMARC record. Failure reports are about a single message and can reveal details about our users, so they are not sent by default. Reports only contain the header of a message, never the body. Reports are not sent about messages rejected for being junk. Reports are sent from the postmaster@<mailhostname> address."`
+
+	Exemptions []Exemption `sconf:"optional" sconf-doc:"Exemptions from checks for trusted networks, e.g. internal relays and monitoring systems, and for authenticated submissions by accounts, e.g. of applications sending bulk messages. All exemptions are configured in this list, so they can be reviewed in one place. Applied exemptions are logged, including their comment."`
+
+	TrustedARCSealers       []string     `sconf:"optional" sconf-doc:"Domains of intermediaries, e.g. mailing lists or university forwarders, whose ARC (Authenticated Received Chain) results are trusted for incoming messages that fail DMARC, typically because the intermediary modified the message. If such a message has a valid ARC chain, the latest ARC set was sealed by one of these domains, and the authentication results recorded by that sealer have a DMARC pass for the domain of the message From address, the DMARC policy is not applied, and the From address is considered validated for reputation-based junk filtering. The override is included in outgoing DMARC aggregate reports as trusted_forwarder."`
+	TrustedARCSealerDomains []dns.Domain `sconf:"-" json:"-"` // Parsed form of TrustedARCSealers.
+
+	LDAP *LDAP `sconf:"optional" sconf-doc:"If set, passwords are also verified against an LDAP directory, for logins with addresses not configured in mox, and for accounts whose password doesn't match. Users are searched for in the directory, and their credentials verified by binding as the user. The user entry is mapped to a mox account through an attribute, and accounts can be created automatically on first login. Two-factor authentication and app password requirements configured in mox still apply. Accounts can still have a local password. Directory passwords can only be used with authentication mechanisms that send the password, like IMAP LOGIN and SASL PLAIN, not with SCRAM-SHA-* and CRAM-MD5."`
+
+	AuditLog *AuditLog `sconf:"optional" sconf-doc:"Configuration for the audit log, with security-relevant events like logins, password and settings changes, configuration changes by admins, account additions and removals, exports and queue changes. The audit log is enabled by default and kept in the auth database. It can be viewed in the admin web interface, and exported with \"mox auditlog export\", as JSON lines or syslog messages."`
+
+	ProvisioningAPI *ProvisioningAPI `sconf:"optional" sconf-doc:"If set, the provisioning API is enabled, a REST/JSON API for creating, suspending, changing the quota of and removing accounts and their addresses, for hosting panels and automation. It is served at provisioning/v0/ below the path of the admin web interface. Requests are authenticated with bearer tokens, managed with \"mox provisioning token\". Mutating requests can have an Idempotency-Key header, a repeated request with the same key gets the original response without making changes again."`
+
+	Signup *Signup `sconf:"optional" sconf-doc:"Abuse protections for the self-service signup of accounts, for domains with Signup configured in domains.conf."`
+
+	DNSProviders map[string]DNSProvider `sconf:"optional" sconf-doc:"DNS providers through which mox can create and update its own DNS records (DKIM, SPF, MTA-STS, TLSA) for domains, and rotate DKIM keys. Domains reference a provider by name in their DNSProvisioning config. Exactly one of the provider types must be set per provider."`
 
 	// All IPs that were explicitly listened on for external SMTP. Only set when there
 	// are no unspecified external SMTP listeners and there is at most one for IPv4 and
@@ -88,6 +118,187 @@ type Static struct {
 	GID uint32 `sconf:"-" json:"-"`
 }
 
+// PostmasterTools configures fetching reputation data from mailbox providers.
+type PostmasterTools struct {
+	SNDSKey string `sconf:"optional" sconf-doc:"Key for automated access to Microsoft Smart Network Data Services (SNDS) data, for the IPs registered at SNDS. Can be found in the SNDS interface, at 'Automated Access'."`
+
+	GoogleClientID     string `sconf:"optional" sconf-doc:"OAuth2 client ID for the Google Postmaster Tools API, for domains verified at Google Postmaster Tools. All three Google fields must be set to fetch data from Google."`
+	GoogleClientSecret string `sconf:"optional" sconf-doc:"OAuth2 client secret for the client ID."`
+	GoogleRefreshToken string `sconf:"optional" sconf-doc:"OAuth2 refresh token, with scope https://www.googleapis.com/auth/postmaster.readonly, for the Google account the domains are verified with."`
+}
+
+// Policies for combining the verdicts of an external spam filter and the Bayesian
+// junk filter.
+const (
+	SpamFilterPolicyEither   = "either"   // Junk if either filter considers the message junk.
+	SpamFilterPolicyBoth     = "both"     // Junk only if both filters consider the message junk.
+	SpamFilterPolicyExternal = "external" // Only the external filter is used.
+)
+
+// Actions for outgoing anomalies.
+const (
+	OutgoingAnomalyAlert    = "alert"    // Only deliver an alert to the postmaster.
+	OutgoingAnomalyThrottle = "throttle" // Also reject submissions while the anomaly persists.
+	OutgoingAnomalySuspend  = "suspend"  // Also suspend sending for the account, until an admin resumes it.
+)
+
+// OutgoingAnomalies configures detection of anomalies in messages submitted by
+// accounts, for detecting compromised accounts.
+type OutgoingAnomalies struct {
+	Action           string `sconf:"optional" sconf-doc:"What to do when a volume spike or high rate of failed recipients is detected, in addition to alerting the postmaster: alert (nothing), throttle (temporarily reject submissions while the anomaly persists) or suspend (reject submissions until an admin resumes sending for the account). Default: throttle."`
+	VolumeFactor     int    `sconf:"optional" sconf-doc:"A volume spike is detected when the number of messages submitted in the past hour is this factor higher than the hourly average over the preceding 7 days. Default 10."`
+	VolumeMinimum    int    `sconf:"optional" sconf-doc:"Minimum number of messages in the past hour before a volume spike can be detected. Default 50."`
+	FailedPercentage int    `sconf:"optional" sconf-doc:"A high rate of failed recipients is detected when delivery failed permanently for at least this percentage of the recipients in the past 24 hours, e.g. because the recipient does not exist. Default 30."`
+	FailedMinimum    int    `sconf:"optional" sconf-doc:"Minimum number of failed recipients in the past 24 hours before a high rate of failed recipients can be detected. Default 10."`
+	UnusualNetworks  bool   `sconf:"optional" sconf-doc:"If set, an alert is delivered when an account with history submits over SMTP from a network (IPv4 /24, IPv6 /48) not used in the past 30 days. Sending is not throttled or suspended."`
+	UnusualHours     bool   `sconf:"optional" sconf-doc:"If set, an alert is delivered when an account with history submits at an hour of the day at which it has not submitted in the past 30 days. Sending is not throttled or suspended."`
+}
+
+type MessageCompression struct {
+	Level   int   `sconf:"optional" sconf-doc:"Compression level, from 1 (fastest) to 9 (smallest). Default 6."`
+	MinSize int64 `sconf:"optional" sconf-doc:"Messages smaller than this size in bytes are not compressed. Default 1024."`
+}
+
+type MessageDeduplication struct {
+	MinSize int64 `sconf:"optional" sconf-doc:"Message files smaller than this size in bytes are not deduplicated. Default 4096."`
+}
+
+// Exemption exempts connections from trusted networks, and authenticated
+// submissions by accounts, from checks.
+type Exemption struct {
+	Comment    string   `sconf:"optional" sconf-doc:"Free-form comment, e.g. why the exemption exists. Included in log lines when the exemption is applied."`
+	Networks   []string `sconf:"optional" sconf-doc:"IP networks in CIDR notation, e.g. 192.0.2.0/24 or 2001:db8::/32, or single IP addresses. The exemption applies to SMTP connections, and incoming deliveries, from these networks."`
+	Accounts   []string `sconf:"optional" sconf-doc:"Accounts whose authenticated submissions, over SMTP, the webmail and the webapi, the exemption applies to, or \"*\" for all accounts. Only RateLimits applies to submissions, they are never junk filtered, checked against DNSBLs or delayed."`
+	JunkFilter bool     `sconf:"optional" sconf-doc:"Incoming deliveries are not evaluated for junk: sender reputation, the junk filter, the external spam filter, content reputation and DNSBLs are skipped, and messages are delivered to their destination mailbox. DMARC reject policies and the sender lists of the account still apply."`
+	DNSBL      bool     `sconf:"optional" sconf-doc:"The remote IP of incoming deliveries is not checked against the DNSBLs of the listener."`
+	Delays     bool     `sconf:"optional" sconf-doc:"Incoming deliveries are not delayed: the first-time sender delay of the listener and the delay before rejecting deliveries to unknown recipients are skipped."`
+	RateLimits bool     `sconf:"optional" sconf-doc:"Rate limits are not applied. For networks: the limits on connection rate, open connections, and messages and bytes delivered per IP and network. For accounts: MaxOutgoingMessagesPerDay, MaxFirstTimeRecipientsPerDay and OutgoingAnomalies."`
+
+	IPNets []net.IPNet `sconf:"-" json:"-"` // Parsed form of Networks.
+}
+
+// SpamFilter configures an external spam filter for incoming messages.
+type SpamFilter struct {
+	Rspamd          string        `sconf:"optional" sconf-doc:"URL of the HTTP API of rspamd (its normal worker), e.g. http://localhost:11333. Messages are submitted to /checkv2. Exactly one of Rspamd and Spamd must be set."`
+	RspamdPassword  string        `sconf:"optional" sconf-doc:"Password for the rspamd HTTP API, if it requires one."`
+	Spamd           string        `sconf:"optional" sconf-doc:"Address of SpamAssassin's spamd, as host:port, e.g. localhost:783."`
+	Timeout         time.Duration `sconf:"optional" sconf-doc:"Maximum duration of a check. Default 30s."`
+	Policy          string        `sconf:"optional" sconf-doc:"How the verdict of the external filter is combined with the verdict of the Bayesian junk filter of the account: either (message is junk if either filter considers it junk), both (message is junk only if both filters consider it junk) or external (only the external filter is used). If the account has no junk filter, only the external filter is used. Default: either."`
+	RejectScore     float64       `sconf:"optional" sconf-doc:"If non-zero, messages with a score from the external filter at or above this value are rejected, regardless of the Bayesian junk filter."`
+	QuarantineScore float64       `sconf:"optional" sconf-doc:"If non-zero, messages with a score from the external filter at or above this value, but below RejectScore, are accepted and delivered to the Junk mailbox of the account. If the account has no mailbox with the Junk special-use role, the message is rejected."`
+}
+
+// ContentReputation configures checking URLs and attachments of incoming messages.
+type ContentReputation struct {
+	URIBLs         []string      `sconf:"optional" sconf-doc:"DNS zones of URI block lists, like SURBL and URIBL, to look up the domains of URLs in text and HTML parts in. Domains are looked up by their organizational domain, e.g. example.com for www.example.com. Example: multi.surbl.org. See the terms of use of the block list providers."`
+	HashBLs        []string      `sconf:"optional" sconf-doc:"DNS zones of block lists with attachment hashes. Attachments are looked up by the lower-case hexadecimal SHA-256 hash of their decoded content, as a label in the zone."`
+	BlockedDomains []string      `sconf:"optional" sconf-doc:"Domains that are not allowed in URLs. Subdomains are also blocked."`
+	HashFile       string        `sconf:"optional" sconf-doc:"File with blocked hexadecimal SHA-256 hashes of attachments, one per line. Empty lines and lines starting with # are ignored. Relative paths are relative to the directory of mox.conf. The file is read when the configuration is loaded."`
+	MaxURLs        int           `sconf:"optional" sconf-doc:"Maximum number of URL domains to check per message, to limit DNS lookups. Default 20."`
+	CacheTTL       time.Duration `sconf:"optional" sconf-doc:"Duration to cache results of DNS block list lookups. Default 1h."`
+	Quarantine     bool          `sconf:"optional" sconf-doc:"Deliver messages with listed URLs or attachments to the Junk mailbox of the account instead of rejecting them. If the account has no mailbox with the Junk special-use role, the message is rejected."`
+
+	URIBLZones           []dns.Domain        `sconf:"-" json:"-"`
+	HashBLZones          []dns.Domain        `sconf:"-" json:"-"`
+	BlockedDomainsParsed []dns.Domain        `sconf:"-" json:"-"`
+	Hashes               map[string]struct{} `sconf:"-" json:"-"` // From HashFile.
+}
+
+// DMARCFailureReports configures sending DMARC failure reports.
+type DMARCFailureReports struct {
+	Redact    bool `sconf:"optional" sconf-doc:"Replace the localparts of email addresses in the reported message header and SMTP envelope with a hash, as described in RFC 6590, so reports don't reveal which of our users received the message. The same localpart is replaced with the same hash while mox is running, so reports can still be correlated."`
+	MaxPerDay int  `sconf:"optional" sconf-doc:"Maximum number of failure reports to send per policy domain per day. Default 10."`
+}
+
+// OIDC configures an OpenID Connect identity provider for logins.
+type OIDC struct {
+	Issuer       string   `sconf-doc:"Issuer URL of the identity provider, e.g. https://sso.example.org/realms/example. Its configuration is fetched from <issuer>/.well-known/openid-configuration. Must be an https URL."`
+	ClientID     string   `sconf-doc:"Client ID of mox as registered at the identity provider. For web logins, the provider must allow redirect URIs ending in oidc/callback below the paths of the account and webmail web interfaces, e.g. https://mail.example.org/webmail/oidc/callback."`
+	ClientSecret string   `sconf:"optional" sconf-doc:"Client secret for the client ID, if the provider requires one."`
+	Scopes       []string `sconf:"optional" sconf-doc:"Additional scopes to request during web logins. Scopes openid and email are always requested."`
+	AddressClaim string   `sconf:"optional" sconf-doc:"Claim with the email address of the user, matched against the addresses of the accounts. The identity provider must set claim email_verified to true, also when another claim is used for the address. Default: email."`
+}
+
+// LDAP configures verifying passwords with an LDAP directory.
+type LDAP struct {
+	URL              string `sconf-doc:"URL of the LDAP server, ldaps://host[:port] for TLS (default port 636), or ldap://host[:port] (default port 389)."`
+	StartTLS         bool   `sconf:"optional" sconf-doc:"For ldap:// URLs, upgrade the connection to TLS with the StartTLS operation before sending credentials. Without StartTLS, passwords are sent in plain text, only use that for connections to localhost."`
+	BindDN           string `sconf:"optional" sconf-doc:"DN to bind as for searching the user entry, e.g. of a service account. If empty, the search is done anonymously."`
+	BindPassword     string `sconf:"optional" sconf-doc:"Password for BindDN."`
+	BaseDN           string `sconf-doc:"DN under which to search for the user entry, e.g. ou=people,dc=example,dc=org."`
+	Filter           string `sconf:"optional" sconf-doc:"Search filter, in RFC 4515 syntax, for finding the user entry with %s replaced by the escaped login name. Must match a single entry. Default: (mail=%s)."`
+	AccountAttribute string `sconf:"optional" sconf-doc:"Attribute of the user entry with the name of the mox account to login to. Default: uid."`
+	AddressAttribute string `sconf:"optional" sconf-doc:"Attribute of the user entry with the email address for an account created with AutoProvision. Default: mail."`
+	AutoProvision    bool   `sconf:"optional" sconf-doc:"Create the account on first successful login if it doesn't exist yet, with the email address from AddressAttribute. The domain of the address must be configured in mox."`
+}
+
+// Signup configures abuse protections for self-service signup.
+type Signup struct {
+	MaxPerIPPerDay   int    `sconf:"optional" sconf-doc:"Maximum number of signups per day from a remote IP address. Limits also apply to the /24 (IPv4) or /48 (IPv6) network, and to the /16 or /32 network, at 3 and 9 times this number. Default 5."`
+	CaptchaVerifyURL string `sconf:"optional" sconf-doc:"If set, signups are verified with a captcha service. The response from the captcha widget is posted to this URL with the secret and the remote IP, as form fields \"secret\", \"response\" and \"remoteip\", and the JSON response must have field \"success\" set to true. Compatible with hCaptcha, reCAPTCHA and Cloudflare Turnstile, e.g. https://hcaptcha.com/siteverify."`
+	CaptchaSecret    string `sconf:"optional" sconf-doc:"Secret for the captcha service."`
+	CaptchaHTML      string `sconf:"optional" sconf-doc:"HTML for the captcha widget, included in the signup form, e.g. a script and div element as documented by the captcha service. The widget must add a form field h-captcha-response, g-recaptcha-response, cf-turnstile-response or captcha-response."`
+}
+
+// ProvisioningAPI configures the provisioning API.
+type ProvisioningAPI struct {
+	WebhookURL           string `sconf:"optional" sconf-doc:"If set, a JSON notification is sent with an HTTP POST to this URL after each change made through the provisioning API. Failed notifications are retried a few times."`
+	WebhookAuthorization string `sconf:"optional" sconf-doc:"Value for the Authorization header in notification requests."`
+	WebhookSigningKey    string `sconf:"optional" sconf-doc:"If set, notifications have a header X-Mox-Webhook-Signature with the hex-encoded HMAC-SHA256 of the request body with this key, prefixed with \"sha256=\"."`
+}
+
+// DNSProvider is an API for changing records in DNS zones.
+type DNSProvider struct {
+	RFC2136    *DNSProviderRFC2136 `sconf:"optional" sconf-doc:"DNS UPDATE messages (RFC 2136) sent to a primary name server, authenticated with TSIG."`
+	Cloudflare *DNSProviderToken   `sconf:"optional" sconf-doc:"Cloudflare API, with an API token with permission to edit DNS records of the zones."`
+	DeSEC      *DNSProviderToken   `sconf:"optional" sconf-doc:"deSEC.io API, with an API token."`
+	Route53    *DNSProviderRoute53 `sconf:"optional" sconf-doc:"Amazon Route 53 API."`
+}
+
+// DNSProviderRFC2136 sends DNS UPDATE messages.
+type DNSProviderRFC2136 struct {
+	Server        string `sconf-doc:"Host and port of the primary name server for the zones, e.g. ns1.example.org:53. Messages are sent over TCP."`
+	TSIGKeyName   string `sconf-doc:"Name of the TSIG key, as configured in the name server."`
+	TSIGSecret    string `sconf-doc:"Base64-encoded TSIG secret."`
+	TSIGAlgorithm string `sconf:"optional" sconf-doc:"TSIG algorithm, hmac-sha256 (default) or hmac-sha512."`
+}
+
+// DNSProviderToken is a DNS provider API with bearer tokens.
+type DNSProviderToken struct {
+	APIToken string `sconf-doc:"API token."`
+	BaseURL  string `sconf:"optional" sconf-doc:"Base URL of the API, to override the default, e.g. for testing."`
+}
+
+// DNSProviderRoute53 is the Amazon Route 53 API.
+type DNSProviderRoute53 struct {
+	AccessKeyID     string `sconf-doc:"AWS access key ID."`
+	SecretAccessKey string `sconf-doc:"AWS secret access key."`
+	HostedZoneID    string `sconf-doc:"ID of the hosted zone, e.g. Z0123456789ABCDEFGHIJ."`
+	BaseURL         string `sconf:"optional" sconf-doc:"Base URL of the API, to override the default, e.g. for testing."`
+}
+
+// AuditLog configures the audit log.
+type AuditLog struct {
+	Disabled  bool          `sconf:"optional" sconf-doc:"Do not record events in the audit log."`
+	Retention time.Duration `sconf:"optional" sconf-doc:"Period after which events are removed from the audit log. Default: 8760h (365 days)."`
+}
+
+// Roles for admin users.
+const (
+	AdminRoleAdmin       = "admin"       // Full access.
+	AdminRoleDomainAdmin = "domainadmin" // Manage specific domains, with their accounts, addresses and aliases.
+	AdminRoleAuditor     = "auditor"     // Read-only access, without secrets.
+)
+
+// AdminUser is a named user for the admin web interface.
+type AdminUser struct {
+	Role         string   `sconf-doc:"Role of the user: admin for full access; domainadmin for managing the domains in Domains, with their accounts (by the default domain of the account), addresses and aliases; auditor for read-only access, without access to secrets like credentials in the configuration files and webhook authorization headers and signing keys of accounts. Roles only apply to the admin web interface, commands through the ctl socket, such as the mox subcommands, always have full access."`
+	Domains      []string `sconf:"optional" sconf-doc:"Domains a domainadmin can manage."`
+	PasswordHash string   `sconf-doc:"Bcrypt hash of the password. Set with \"mox config adminuser setpassword\"." json:"-"`
+
+	DNSDomains []dns.Domain `sconf:"-" json:"-"` // Parsed form of Domains.
+}
+
 // InitialMailboxes are mailboxes created for a new account.
 type InitialMailboxes struct {
 	SpecialUse SpecialUseMailboxes `sconf:"optional" sconf-doc:"Special-use roles to mailbox to create."`
@@ -107,12 +318,15 @@ type SpecialUseMailboxes struct {
 
 // Dynamic is the parsed form of domains.conf, and is automatically reloaded when changed.
 type Dynamic struct {
-	Domains            map[string]Domain  `sconf-doc:"NOTE: This config file is in 'sconf' format. Indent with tabs. Comments must be on their own line, they don't end a line. Do not escape or quote strings. Details: https://pkg.go.dev/github.com/mjl-/sconf.\n\n\nDomains for which email is accepted. For internationalized domains, use their IDNA names in UTF-8."`
-	Accounts           map[string]Account `sconf-doc:"Accounts represent mox users, each with a password and email address(es) to which email can be delivered (possibly at different domains). Each account has its own on-disk directory holding its messages and index database. An account name is not an email address."`
-	WebDomainRedirects map[string]string  `sconf:"optional" sconf-doc:"Redirect all requests from domain (key) to domain (value). Always redirects to HTTPS. For plain HTTP redirects, use a WebHandler with a WebRedirect."`
-	WebHandlers        []WebHandler       `sconf:"optional" sconf-doc:"Handle webserver requests by serving static files, redirecting, reverse-proxying HTTP(s) or passing the request to an internal service. The first matching WebHandler will handle the request. Built-in system handlers, e.g. for ACME validation, autoconfig and mta-sts always run first. Built-in handlers for admin, account, webmail and webapi are evaluated after all handlers, including webhandlers (allowing for overrides of internal services for some domains). If no handler matches, the response status code is file not found (404). If webserver features are missing, forward the requests to an application that provides the needed functionality itself."`
-	Routes             []Route            `sconf:"optional" sconf-doc:"Routes for delivering outgoing messages through the queue. Each delivery attempt evaluates account routes, domain routes and finally these global routes. The transport of the first matching route is used in the delivery attempt. If no routes match, which is the default with no configured routes, messages are delivered directly from the queue."`
-	MonitorDNSBLs      []string           `sconf:"optional" sconf-doc:"DNS blocklists to periodically check with if IPs we send from are present, without using them for checking incoming deliveries.. Also see DNSBLs in SMTP listeners in mox.conf, which specifies DNSBLs to use both for incoming deliveries and for checking our IPs against. Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net."`
+	Domains            map[string]Domain    `sconf-doc:"NOTE: This config file is in 'sconf' format. Indent with tabs. Comments must be on their own line, they don't end a line. Do not escape or quote strings. Details: https://pkg.go.dev/github.com/mjl-/sconf.\n\n\nDomains for which email is accepted. For internationalized domains, use their IDNA names in UTF-8."`
+	Accounts           map[string]Account   `sconf-doc:"Accounts represent mox users, each with a password and email address(es) to which email can be delivered (possibly at different domains). Each account has its own on-disk directory holding its messages and index database. An account name is not an email address."`
+	WebDomainRedirects map[string]string    `sconf:"optional" sconf-doc:"Redirect all requests from domain (key) to domain (value). Always redirects to HTTPS. For plain HTTP redirects, use a WebHandler with a WebRedirect."`
+	WebHandlers        []WebHandler         `sconf:"optional" sconf-doc:"Handle webserver requests by serving static files, redirecting, reverse-proxying HTTP(s) or passing the request to an internal service. The first matching WebHandler will handle the request. Built-in system handlers, e.g. for ACME validation, autoconfig and mta-sts always run first. Built-in handlers for admin, account, webmail and webapi are evaluated after all handlers, including webhandlers (allowing for overrides of internal services for some domains). If no handler matches, the response status code is file not found (404). If webserver features are missing, forward the requests to an application that provides the needed functionality itself."`
+	Routes             []Route              `sconf:"optional" sconf-doc:"Routes for delivering outgoing messages through the queue. Each delivery attempt evaluates account routes, domain routes and finally these global routes. The transport of the first matching route is used in the delivery attempt. If no routes match, which is the default with no configured routes, messages are delivered directly from the queue."`
+	RetrySchedules     []RetrySchedule      `sconf:"optional" sconf-doc:"Schedules for retrying delivery of outgoing messages from the queue after temporary failures. The first matching schedule is used. If no schedule matches, which is the default with no configured schedules, deliveries are attempted after 7.5m, 15m, 30m, 1h, 2h, 4h and 8h, giving up after 8 attempts."`
+	TLSPolicies        []TLSPolicy          `sconf:"optional" sconf-doc:"TLS policies for direct delivery of outgoing messages from the queue to recipient domains, overriding the behaviour based on MTA-STS and DANE. The first matching policy is used. Delivery attempts that do not satisfy the policy fail temporarily. If no policy matches, which is the default with no configured policies, STARTTLS is opportunistic unless required by MTA-STS or DANE, with fallback to plain text for failing TLS connections."`
+	MonitorDNSBLs      []string             `sconf:"optional" sconf-doc:"DNS blocklists to periodically check with if IPs we send from are present, without using them for checking incoming deliveries.. Also see DNSBLs in SMTP listeners in mox.conf, which specifies DNSBLs to use both for incoming deliveries and for checking our IPs against. Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net."`
+	AdminUsers         map[string]AdminUser `sconf:"optional" json:"-" sconf-doc:"Named users for the admin web interface, each with a role limiting what they can do. Logins with only the admin password from mox.conf (AdminPasswordFile) have full access. Manage with the \"mox config adminuser\" subcommands, or in the admin web interface."`
 
 	WebDNSDomainRedirects map[dns.Domain]dns.Domain `sconf:"-" json:"-"`
 	MonitorDNSBLZones     []dns.Domain              `sconf:"-"`
@@ -127,10 +341,24 @@ type ACME struct {
 	IssuerDomainName       string                  `sconf:"optional" sconf-doc:"If set, used for suggested CAA DNS records, for restricting TLS certificate issuance to a Certificate Authority. If empty and DirectyURL is for Let's Encrypt, this value is set automatically to letsencrypt.org."`
 	ExternalAccountBinding *ExternalAccountBinding `sconf:"optional" sconf-doc:"ACME providers can require that a request for a new ACME account reference an existing non-ACME account known to the provider. External account binding references that account by a key id, and authorizes new ACME account requests by signing it with a key known both by the ACME client and ACME provider."`
 	// ../rfc/8555:2111
+	DNS01 *ACMEDNS01 `sconf:"optional" sconf-doc:"If set, certificates are requested with DNS-01 challenges, by setting TXT records through a DNS provider, instead of with tls-alpn-01 or http-01. Hosts then do not have to be reachable from the internet on port 443 or 80. Also required for wildcard certificates."`
+
+	BlockDANERollover bool `sconf:"optional" sconf-doc:"When requesting a new certificate for a host with HostPrivateKeyFiles, and the first configured key (used for new certificates) differs from the key of the current certificate, and the host has DNSSEC-signed DANE TLSA records but none for the new key, keep using the key of the current certificate for the new certificate. Prevents breaking incoming deliveries from servers that verify with DANE when rotating keys before the TLSA records are updated. Without this option, an error is logged and the new key is used. See the DANE page in the admin web interface for the current and next TLSA records."`
 
 	Manager *autotls.Manager `sconf:"-" json:"-"`
 }
 
+// ACMEDNS01 configures DNS-01 challenges for ACME.
+type ACMEDNS01 struct {
+	Provider         string        `sconf-doc:"Name of DNS provider in DNSProviders, used to set the TXT records at _acme-challenge.<host>."`
+	Zones            []string      `sconf-doc:"DNS zones at the DNS provider. The TXT record for a host is set in the longest zone that contains the host, e.g. zone example.com for _acme-challenge.mail.example.com."`
+	PropagationDelay time.Duration `sconf:"optional" sconf-doc:"Time to wait after setting a TXT record before asking the ACME provider to validate it, for the record to reach all authoritative name servers. Default 30s."`
+	Wildcards        []string      `sconf:"optional" sconf-doc:"Domains for which a wildcard certificate is requested, e.g. example.com for *.example.com. The wildcard certificate is used for all hosts directly under the domain, such as mta-sts.example.com and autoconfig.example.com, instead of requesting a certificate per host. Reduces the number of certificates and ACME requests for deployments with many domains."`
+
+	DNSZones        []dns.Domain `sconf:"-" json:"-"`
+	WildcardDomains []dns.Domain `sconf:"-" json:"-"`
+}
+
 type ExternalAccountBinding struct {
 	KeyID   string `sconf-doc:"Key identifier, from ACME provider."`
 	KeyFile string `sconf-doc:"File containing the base64url-encoded key used to sign account requests with external account binding. The ACME provider will verify the account request is correctly signed by the key. File is evaluated relative to the directory of mox.conf."`
@@ -154,12 +382,15 @@ type Listener struct {
 		// Reoriginated messages (such as messages sent to mailing list subscribers) should
 		// keep REQUIRETLS. ../rfc/8689:412
 
-		DNSBLs []string `sconf:"optional" sconf-doc:"Addresses of DNS block lists for incoming messages. Block lists are only consulted for connections/messages without enough reputation to make an accept/reject decision. This prevents sending IPs of all communications to the block list provider. If any of the listed DNSBLs contains a requested IP address, the message is rejected as spam. The DNSBLs are checked for healthiness before use, at most once per 4 hours. IPs we can send from are periodically checked for being in the configured DNSBLs. See MonitorDNSBLs in domains.conf to only monitor IPs we send from, without using those DNSBLs for incoming messages. Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net. See https://www.spamhaus.org/sbl/ and https://www.spamcop.net/ for more information and terms of use."`
+		DNSBLs []string `sconf:"optional" sconf-doc:"Addresses of DNS block lists for incoming messages. Block lists are only consulted for connections/messages without enough reputation to make an accept/reject decision. This prevents sending IPs of all communications to the block list provider. If any of the listed DNSBLs contains a requested IP address, the message is rejected as spam. The DNSBLs are checked for healthiness periodically, lists that fail their health check or that repeatedly time out are not used until they are healthy again. Lookup results are cached, for 1 hour for listed IPs and 15 minutes for IPs that are not listed. IPs we can send from are periodically checked for being in the configured DNSBLs. See MonitorDNSBLs in domains.conf to only monitor IPs we send from, without using those DNSBLs for incoming messages. Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net. See https://www.spamhaus.org/sbl/ and https://www.spamcop.net/ for more information and terms of use."`
 
 		FirstTimeSenderDelay *time.Duration `sconf:"optional" sconf-doc:"Delay before accepting a message from a first-time sender for the destination account. Default: 15s."`
 
 		TLSSessionTicketsDisabled *bool `sconf:"optional" sconf-doc:"Override default setting for enabling TLS session tickets. Disabling session tickets may work around TLS interoperability issues."`
 
+		TLSFingerprintsBlocked    []string `sconf:"optional" sconf-doc:"TLS client fingerprints to reject incoming connections for, in JA3 (32 hexadecimal characters, e.g. 6734f37431670b3ab4292b8f60f29984) or JA4 form (e.g. t13d1516h2_8daaf6152771_e5627efa2ab1). A fingerprint identifies the TLS implementation and its configuration of the remote, and can catch botnets that rotate IPs but keep their TLS stack. Fingerprints of incoming TLS connections are logged. Connections with a blocked fingerprint are rejected right after the TLS handshake."`
+		TLSFingerprintsSuspicious []string `sconf:"optional" sconf-doc:"TLS client fingerprints, in the same form as TLSFingerprintsBlocked, for which a stricter junk filter threshold is applied to incoming messages from senders without reputation."`
+
 		DNSBLZones []dns.Domain `sconf:"-"`
 	} `sconf:"optional"`
 	Submission struct {
@@ -190,6 +421,10 @@ type Listener struct {
 	WebmailHTTPS WebService `sconf:"optional" sconf-doc:"Webmail client, like WebmailHTTP, but for HTTPS. Requires a TLS config."`
 	WebAPIHTTP   WebService `sconf:"optional" sconf-doc:"Like WebAPIHTTP, but with plain HTTP, without TLS."`
 	WebAPIHTTPS  WebService `sconf:"optional" sconf-doc:"WebAPI, a simple HTTP/JSON-based API for email, with HTTPS (requires a TLS config). Default path is /webapi/."`
+	CalDAVHTTP   WebService `sconf:"optional" sconf-doc:"Like CalDAVHTTPS, but with plain HTTP, without TLS."`
+	CalDAVHTTPS  WebService `sconf:"optional" sconf-doc:"CalDAV server for synchronizing calendars of accounts with calendar applications, with HTTPS (requires a TLS config). Meeting invitations delivered by email are added to the scheduling inbox. Clients can find the server through /.well-known/caldav. Default path is /caldav/."`
+	CardDAVHTTP  WebService `sconf:"optional" sconf-doc:"Like CardDAVHTTPS, but with plain HTTP, without TLS."`
+	CardDAVHTTPS WebService `sconf:"optional" sconf-doc:"CardDAV server for synchronizing address books of accounts with contact applications, with HTTPS (requires a TLS config). Addresses that messages are sent to repeatedly are added to the address book named \"collected\". Clients can find the server through /.well-known/carddav. Default path is /carddav/."`
 	MetricsHTTP  struct {
 		Enabled bool
 		Port    int `sconf:"optional" sconf-doc:"Default 8010."`
@@ -208,6 +443,16 @@ type Listener struct {
 		Port    int  `sconf:"optional" sconf-doc:"TLS port, 443 by default. You should only override this if you cannot listen on port 443 directly. MTA-STS requests will be made to port 443, so you'll have to add an external mechanism to get the connection here, e.g. by configuring port forwarding."`
 		NonTLS  bool `sconf:"optional" sconf-doc:"If set, plain HTTP instead of HTTPS is spoken on the configured port. Can be useful when the mta-sts domain is reverse proxied."`
 	} `sconf:"optional" sconf-doc:"Serve MTA-STS policies describing SMTP TLS requirements. Requires a TLS config."`
+	WKDHTTPS struct {
+		Enabled bool
+		Port    int  `sconf:"optional" sconf-doc:"TLS port, 443 by default. You should only override this if you cannot listen on port 443 directly. Web Key Directory requests will be made to port 443, so you'll have to add an external mechanism to get the connection here, e.g. by configuring port forwarding."`
+		NonTLS  bool `sconf:"optional" sconf-doc:"If set, plain HTTP instead of HTTPS is spoken on the configured port. Can be useful when the openpgpkey domain is reverse proxied."`
+	} `sconf:"optional" sconf-doc:"Serve OpenPGP keys that accounts have published, in the OpenPGP Web Key Directory (WKD) at openpgpkey.<domain>, for email applications looking up keys for encrypting messages. A DNS record for openpgpkey.<domain> pointing to this listener is required. Requires a TLS config."`
+	BIMIHTTPS struct {
+		Enabled bool
+		Port    int  `sconf:"optional" sconf-doc:"TLS port, 443 by default. You should only override this if you cannot listen on port 443 directly. BIMI logos will be requested on port 443, so you'll have to add an external mechanism to get the connection here, e.g. by configuring port forwarding."`
+		NonTLS  bool `sconf:"optional" sconf-doc:"If set, plain HTTP instead of HTTPS is spoken on the configured port. Can be useful when the bimi domain is reverse proxied."`
+	} `sconf:"optional" sconf-doc:"Serve BIMI logos and certificates of domains with BIMI configured at bimi.<domain>. A DNS record for bimi.<domain> pointing to this listener is required. Requires a TLS config."`
 	WebserverHTTP struct {
 		Enabled bool
 		Port    int `sconf:"optional" sconf-doc:"Port for plain HTTP (non-TLS) webserver."`
@@ -275,22 +520,38 @@ type TransportDirect struct {
 	DisableIPv4 bool `sconf:"optional" sconf-doc:"If set, outgoing SMTP connections will *NOT* use IPv4 addresses to connect to remote SMTP servers."`
 	DisableIPv6 bool `sconf:"optional" sconf-doc:"If set, outgoing SMTP connections will *NOT* use IPv6 addresses to connect to remote SMTP servers."`
 
+	PreferIPFamily       string        `sconf:"optional" sconf-doc:"Address family to connect with first for the first delivery attempt to a remote SMTP server that has both IPv4 and IPv6 addresses: ipv6 or ipv4. If empty, the order of IPs from DNS is used. For later delivery attempts, the other address family is tried first, e.g. to work around a blocklisted IP. Use a route with ToDomain to a direct transport with another preference to override the preference for specific destination domains."`
+	HappyEyeballsDelay   time.Duration `sconf:"optional" sconf-doc:"Delay before starting a connection attempt to the next IP address of a remote SMTP server while a previous attempt is still in progress, alternating between IPv6 and IPv4 addresses. The first connection that succeeds is used, so a destination with broken IPv6 (or IPv4) connectivity doesn't cause a delay until a connection timeout. Known as \"happy eyeballs\", RFC 8305. Default 250ms."`
+	DisableHappyEyeballs bool          `sconf:"optional" sconf-doc:"If set, IP addresses of a remote SMTP server are dialed one at a time, only trying the next IP after the previous connection attempt failed or timed out."`
+
 	IPFamily string `sconf:"-" json:"-"`
 }
 
 type Domain struct {
-	Disabled                    bool             `sconf:"optional" sconf-doc:"Disabled domains can be useful during/before migrations. Domains that are disabled can still be configured like normal, including adding addresses using the domain to accounts. However, disabled domains: 1. Do not try to fetch ACME certificates. TLS connections to host names involving the email domain will fail. A TLS certificate for the hostname (that wil be used as MX) itself will be requested. 2. Incoming deliveries over SMTP are rejected with a temporary error '450 4.2.1 recipient domain temporarily disabled'. 3. Submissions over SMTP using an (envelope) SMTP MAIL FROM address or message 'From' address of a disabled domain will be rejected with a temporary error '451 4.3.0 sender domain temporarily disabled'. Note that accounts with addresses at disabled domains can still log in and read email (unless the account itself is disabled)."`
-	Description                 string           `sconf:"optional" sconf-doc:"Free-form description of domain."`
-	ClientSettingsDomain        string           `sconf:"optional" sconf-doc:"Hostname for client settings instead of the mail server hostname. E.g. mail.<domain>. For future migration to another mail operator without requiring all clients to update their settings, it is convenient to have client settings that reference a subdomain of the hosted domain instead of the hostname of the server where the mail is currently hosted. If empty, the hostname of the mail server is used for client configurations. Unicode name."`
-	LocalpartCatchallSeparator  string           `sconf:"optional" sconf-doc:"If not empty, only the string before the separator is used to for email delivery decisions. For example, if set to \"+\", you+anything@example.com will be delivered to you@example.com."`
-	LocalpartCatchallSeparators []string         `sconf:"optional" sconf-doc:"Similar to LocalpartCatchallSeparator, but in case multiple are needed. For example both \"+\" and \"-\". Only of one LocalpartCatchallSeparator or LocalpartCatchallSeparators can be set. If set, the first separator is used to make unique addresses for outgoing SMTP connections with FromIDLoginAddresses."`
-	LocalpartCaseSensitive      bool             `sconf:"optional" sconf-doc:"If set, upper/lower case is relevant for email delivery."`
-	DKIM                        DKIM             `sconf:"optional" sconf-doc:"With DKIM signing, a domain is taking responsibility for (content of) emails it sends, letting receiving mail servers build up a (hopefully positive) reputation of the domain, which can help with mail delivery."`
-	DMARC                       *DMARC           `sconf:"optional" sconf-doc:"With DMARC, a domain publishes, in DNS, a policy on how other mail servers should handle incoming messages with the From-header matching this domain and/or subdomain (depending on the configured alignment). Receiving mail servers use this to build up a reputation of this domain, which can help with mail delivery. A domain can also publish an email address to which reports about DMARC verification results can be sent by verifying mail servers, useful for monitoring. Incoming DMARC reports are automatically parsed, validated, added to metrics and stored in the reporting database for later display in the admin web pages."`
-	MTASTS                      *MTASTS          `sconf:"optional" sconf-doc:"MTA-STS is a mechanism that allows publishing a policy with requirements for WebPKI-verified SMTP STARTTLS connections for email delivered to a domain. Existence of a policy is announced in a DNS TXT record (often unprotected/unverified, MTA-STS's weak spot). If a policy exists, it is fetched with a WebPKI-verified HTTPS request. The policy can indicate that WebPKI-verified SMTP STARTTLS is required, and which MX hosts (optionally with a wildcard pattern) are allowd. MX hosts to deliver to are still taken from DNS (again, not necessarily protected/verified), but messages will only be delivered to domains matching the MX hosts from the published policy. Mail servers look up the MTA-STS policy when first delivering to a domain, then keep a cached copy, periodically checking the DNS record if a new policy is available, and fetching and caching it if so. To update a policy, first serve a new policy with an updated policy ID, then update the DNS record (not the other way around). To remove an enforced policy, publish an updated policy with mode \"none\" for a long enough period so all cached policies have been refreshed (taking DNS TTL and policy max age into account), then remove the policy from DNS, wait for TTL to expire, and stop serving the policy."`
-	TLSRPT                      *TLSRPT          `sconf:"optional" sconf-doc:"With TLSRPT a domain specifies in DNS where reports about encountered SMTP TLS behaviour should be sent. Useful for monitoring. Incoming TLS reports are automatically parsed, validated, added to metrics and stored in the reporting database for later display in the admin web pages."`
-	Routes                      []Route          `sconf:"optional" sconf-doc:"Routes for delivering outgoing messages through the queue. Each delivery attempt evaluates account routes, these domain routes and finally global routes. The transport of the first matching route is used in the delivery attempt. If no routes match, which is the default with no configured routes, messages are delivered directly from the queue."`
-	Aliases                     map[string]Alias `sconf:"optional" sconf-doc:"Aliases that cause messages to be delivered to one or more locally configured addresses. Keys are localparts (encoded, as they appear in email addresses)."`
+	Disabled                    bool                 `sconf:"optional" sconf-doc:"Disabled domains can be useful during/before migrations. Domains that are disabled can still be configured like normal, including adding addresses using the domain to accounts. However, disabled domains: 1. Do not try to fetch ACME certificates. TLS connections to host names involving the email domain will fail. A TLS certificate for the hostname (that wil be used as MX) itself will be requested. 2. Incoming deliveries over SMTP are rejected with a temporary error '450 4.2.1 recipient domain temporarily disabled'. 3. Submissions over SMTP using an (envelope) SMTP MAIL FROM address or message 'From' address of a disabled domain will be rejected with a temporary error '451 4.3.0 sender domain temporarily disabled'. Note that accounts with addresses at disabled domains can still log in and read email (unless the account itself is disabled)."`
+	Description                 string               `sconf:"optional" sconf-doc:"Free-form description of domain."`
+	ClientSettingsDomain        string               `sconf:"optional" sconf-doc:"Hostname for client settings instead of the mail server hostname. E.g. mail.<domain>. For future migration to another mail operator without requiring all clients to update their settings, it is convenient to have client settings that reference a subdomain of the hosted domain instead of the hostname of the server where the mail is currently hosted. If empty, the hostname of the mail server is used for client configurations. Unicode name."`
+	LocalpartCatchallSeparator  string               `sconf:"optional" sconf-doc:"If not empty, only the string before the separator is used to for email delivery decisions. For example, if set to \"+\", you+anything@example.com will be delivered to you@example.com."`
+	LocalpartCatchallSeparators []string             `sconf:"optional" sconf-doc:"Similar to LocalpartCatchallSeparator, but in case multiple are needed. For example both \"+\" and \"-\". Only of one LocalpartCatchallSeparator or LocalpartCatchallSeparators can be set. If set, the first separator is used to make unique addresses for outgoing SMTP connections with FromIDLoginAddresses."`
+	LocalpartCaseSensitive      bool                 `sconf:"optional" sconf-doc:"If set, upper/lower case is relevant for email delivery."`
+	DKIM                        DKIM                 `sconf:"optional" sconf-doc:"With DKIM signing, a domain is taking responsibility for (content of) emails it sends, letting receiving mail servers build up a (hopefully positive) reputation of the domain, which can help with mail delivery."`
+	DMARC                       *DMARC               `sconf:"optional" sconf-doc:"With DMARC, a domain publishes, in DNS, a policy on how other mail servers should handle incoming messages with the From-header matching this domain and/or subdomain (depending on the configured alignment). Receiving mail servers use this to build up a reputation of this domain, which can help with mail delivery. A domain can also publish an email address to which reports about DMARC verification results can be sent by verifying mail servers, useful for monitoring. Incoming DMARC reports are automatically parsed, validated, added to metrics and stored in the reporting database for later display in the admin web pages."`
+	MTASTS                      *MTASTS              `sconf:"optional" sconf-doc:"MTA-STS is a mechanism that allows publishing a policy with requirements for WebPKI-verified SMTP STARTTLS connections for email delivered to a domain. Existence of a policy is announced in a DNS TXT record (often unprotected/unverified, MTA-STS's weak spot). If a policy exists, it is fetched with a WebPKI-verified HTTPS request. The policy can indicate that WebPKI-verified SMTP STARTTLS is required, and which MX hosts (optionally with a wildcard pattern) are allowd. MX hosts to deliver to are still taken from DNS (again, not necessarily protected/verified), but messages will only be delivered to domains matching the MX hosts from the published policy. Mail servers look up the MTA-STS policy when first delivering to a domain, then keep a cached copy, periodically checking the DNS record if a new policy is available, and fetching and caching it if so. To update a policy, first serve a new policy with an updated policy ID, then update the DNS record (not the other way around). To remove an enforced policy, publish an updated policy with mode \"none\" for a long enough period so all cached policies have been refreshed (taking DNS TTL and policy max age into account), then remove the policy from DNS, wait for TTL to expire, and stop serving the policy."`
+	TLSRPT                      *TLSRPT              `sconf:"optional" sconf-doc:"With TLSRPT a domain specifies in DNS where reports about encountered SMTP TLS behaviour should be sent. Useful for monitoring. Incoming TLS reports are automatically parsed, validated, added to metrics and stored in the reporting database for later display in the admin web pages."`
+	Routes                      []Route              `sconf:"optional" sconf-doc:"Routes for delivering outgoing messages through the queue. Each delivery attempt evaluates account routes, these domain routes and finally global routes. The transport of the first matching route is used in the delivery attempt. If no routes match, which is the default with no configured routes, messages are delivered directly from the queue."`
+	Aliases                     map[string]Alias     `sconf:"optional" sconf-doc:"Aliases that cause messages to be delivered to one or more locally configured addresses. Keys are localparts (encoded, as they appear in email addresses)."`
+	OutgoingHeaderRules         *OutgoingHeaderRules `sconf:"optional" sconf-doc:"Changes to the message header of outgoing messages with a message From address of this domain, made during submission before DKIM signing. Rules of the account are applied after those of the domain."`
+	OutgoingFooter              *OutgoingFooter      `sconf:"optional" sconf-doc:"Footer, e.g. a disclaimer, added to the text of outgoing messages with a message From address of this domain, during submission. Not used if the account has its own footer."`
+	MessageTemplates            []MessageTemplate    `sconf:"optional" sconf-doc:"Templates for composing messages in the webmail, e.g. canned responses, shared with all accounts that have an address in this domain. Accounts can also have their own templates, managed in the webmail."`
+	RequireTwoFactor            bool                 `sconf:"optional" sconf-doc:"If set, accounts with this domain as their default domain must use two-factor authentication, as if RequireTwoFactor is set for the account."`
+	QuotaMessageSize            int64                `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for accounts with this domain as their default domain, overriding the globally configured default if non-zero. Can be overridden per account. A negative value can be used to have no limit in case there is a limit by default."`
+	DNSProvisioning             *DNSProvisioning     `sconf:"optional" sconf-doc:"If set, mox creates and updates DNS records for this domain through a DNS provider: DKIM records for its selectors, the SPF record, the MTA-STS record, the BIMI record and TLSA records for the mail host if it is in the zone. DKIM keys can be rotated automatically."`
+	Signup                      *DomainSignup        `sconf:"optional" sconf-doc:"If set, accounts with an address at this domain can be requested through the self-service signup page, served at signup/ below the path of the account web interface. Signups require an invitation created by an admin, unless open. The contact email address given in the signup is verified before the account is created. Role addresses like postmaster and abuse, and the DMARC and TLS reporting addresses, cannot be requested. Abuse protections are configured with Signup in mox.conf."`
+	Web                         *DomainWeb           `sconf:"optional" sconf-doc:"Customization of the webmail and account web interfaces, e.g. for hosting providers to white-label the interfaces for customer domains. Branding applies to requests with a Host header for this domain or a subdomain, e.g. mail.<domain>, unless the subdomain is a configured domain itself. Default webmail settings and disabled features apply to accounts with this domain as their default domain."`
+	BIMI                        *BIMI                `sconf:"optional" sconf-doc:"BIMI (Brand Indicators for Message Identification) lets mail clients of recipients show the logo of the domain for messages that pass DMARC, if the DMARC policy of the domain is quarantine (for all messages) or reject. The logo location is published in a DNS TXT record, the logo can be served by mox."`
+	SpamActions                 *SpamActions         `sconf:"optional" sconf-doc:"Default actions for incoming messages based on the spaminess score of the junk filter, for accounts with this domain as their default domain. Fields set in the JunkFilter of an account take precedence."`
+	SharedJunkFilter            *SharedJunkFilter    `sconf:"optional" sconf-doc:"If set, a junk filter shared by all accounts with this domain as their default domain is trained along with the per-account junk filters, and its spaminess score is combined with that of the per-account junk filter during incoming deliveries. Useful for new accounts that have not trained their own junk filter yet. Only used for accounts with a JunkFilter configured."`
+	Spamtraps                   []string             `sconf:"optional" sconf-doc:"Localparts of spamtrap addresses in this domain. Spamtrap addresses are never given out, so only spammers, e.g. with harvested or guessed addresses, send to them. Messages to spamtraps are accepted but never delivered. Instead, the server-wide reputation of the sending IP and the validated message From domain are updated, and the message is trained as spam in the shared junk filter of the domain, if configured. To protect against trap poisoning, messages with a null reverse path (e.g. bounces) are ignored, messages are not trained for senders with an allow override or with messages marked as not junk by users, and only the first messages of a sending IP are trained. Spamtraps must not also be configured as address of an account or alias. Localparts are encoded, as they appear in email addresses."`
 
 	Domain                  dns.Domain `sconf:"-"`
 	ClientSettingsDNSDomain dns.Domain `sconf:"-" json:"-"`
@@ -298,8 +559,9 @@ type Domain struct {
 	// Set when DMARC and TLSRPT (when set) has an address with different domain (we're
 	// hosting the reporting), and there are no destination addresses configured for
 	// the domain. Disables some functionality related to hosting a domain.
-	ReportsOnly                          bool     `sconf:"-" json:"-"`
-	LocalpartCatchallSeparatorsEffective []string `sconf:"-"` // Either LocalpartCatchallSeparators, the value of LocalpartCatchallSeparator, or empty.
+	ReportsOnly                          bool             `sconf:"-" json:"-"`
+	LocalpartCatchallSeparatorsEffective []string         `sconf:"-"`          // Either LocalpartCatchallSeparators, the value of LocalpartCatchallSeparator, or empty.
+	SpamtrapLocalparts                   []smtp.Localpart `sconf:"-" json:"-"` // Canonical localparts of Spamtraps.
 }
 
 // todo: allow external addresses as members of aliases. we would add messages for them to the queue for outgoing delivery. we should require an admin addresses to which delivery failures will be delivered (locally, and to use in smtp mail from, so dsns go there). also take care to evaluate smtputf8 (if external address requires utf8 and incoming transaction didn't).
@@ -311,16 +573,56 @@ type Alias struct {
 	PostPublic   bool     `sconf:"optional" sconf-doc:"If true, anyone can send messages to the list. Otherwise only members, based on message From address, which is assumed to be DMARC-like-verified."`
 	ListMembers  bool     `sconf:"optional" sconf-doc:"If true, members can see addresses of members."`
 	AllowMsgFrom bool     `sconf:"optional" sconf-doc:"If true, members are allowed to send messages with this alias address in the message From header."`
+	PostPolicy   string   `sconf:"optional" sconf-doc:"Who can send messages to the list, overriding PostPublic if set. Values: members (only members, based on message From address), anyone, moderated (only owners; messages from others, including members, are only delivered to the owners for review, who can resend approved messages to the list)."`
+	Owners       []string `sconf:"optional" sconf-doc:"Addresses of local accounts that manage the list. Owners can add and remove members in the account web interface, and are the moderators of a moderated list. Owners don't have to be members."`
+	ListHeaders  bool     `sconf:"optional" sconf-doc:"If true, List-Id, List-Post and List-Unsubscribe headers are added to messages delivered through the alias, so mail clients can recognize the list and show an unsubscribe option. The List-Unsubscribe header links to the account web interface, where members can leave the list."`
+
+	Members map[string]AliasMember `sconf:"optional" sconf-doc:"Delivery preferences of members, keyed by member address. Members change their own preferences in the account web interface."`
+
+	List *AliasList `sconf:"optional" sconf-doc:"If set, the alias is also a mailing list with subscribers, which can be external addresses. Messages to the list are sent to confirmed subscribers through the queue, with list headers, DKIM-signed and ARC-sealed with the keys of the domain, and with the From address rewritten to the list address if the DMARC policy of the author domain would cause the message to be rejected. Subscribers can choose to receive a daily digest instead. Email commands are sent to the list address with the command after a localpart catchall separator, e.g. list+subscribe@example.com and list+unsubscribe@example.com, so the domain must have a LocalpartCatchallSeparator. Subscribers can also manage their subscription on the web page at list/ below the account web interface. Subscribers can send to the list like members."`
 
 	LocalpartStr    string         `sconf:"-"` // In encoded form.
 	Domain          dns.Domain     `sconf:"-"`
 	ParsedAddresses []AliasAddress `sconf:"-"` // Matches addresses.
+	ParsedOwners    []AliasAddress `sconf:"-"` // Matches Owners.
+}
+
+// Post policies for aliases.
+const (
+	AliasPostMembers   = "members"
+	AliasPostAnyone    = "anyone"
+	AliasPostModerated = "moderated"
+)
+
+// EffectivePostPolicy returns the configured PostPolicy, or the policy
+// corresponding to PostPublic if not set.
+func (a Alias) EffectivePostPolicy() string {
+	if a.PostPolicy != "" {
+		return a.PostPolicy
+	} else if a.PostPublic {
+		return AliasPostAnyone
+	}
+	return AliasPostMembers
+}
+
+// AliasList configures the mailing list features of an alias.
+type AliasList struct {
+	Subscribe   bool `sconf:"optional" sconf-doc:"If true, anyone can subscribe, by sending a message with command subscribe or through the web page. Subscriptions must be confirmed through a message sent to the subscribing address (double opt-in). Otherwise, only admins add subscribers. Unsubscribing is always possible."`
+	Archive     bool `sconf:"optional" sconf-doc:"If true, messages sent to the list are kept in an archive, viewable by admins."`
+	BounceLimit int  `sconf:"optional" sconf-doc:"Number of delivery failures after which a subscriber is automatically unsubscribed. Failures are forgotten after 30 days without failures. Default 5."`
+}
+
+// AliasMember holds delivery preferences of a member of an alias.
+type AliasMember struct {
+	NoDelivery bool   `sconf:"optional" sconf-doc:"If true, messages to the list are not delivered to the member. The member can still send to the list."`
+	Mailbox    string `sconf:"optional" sconf-doc:"Mailbox to deliver messages from the list to, instead of the mailbox of the destination of the member address."`
 }
 
 type AliasAddress struct {
 	Address     smtp.Address // Parsed address.
 	AccountName string       // Looked up.
 	Destination Destination  // Belonging to address.
+	Member      AliasMember  // Delivery preferences, from Alias.Members.
 }
 
 type DMARC struct {
@@ -329,6 +631,8 @@ type DMARC struct {
 	Account   string `sconf-doc:"Account to deliver to."`
 	Mailbox   string `sconf-doc:"Mailbox to deliver to, e.g. DMARC."`
 
+	FailureAlertPercentage int `sconf:"optional" sconf-doc:"If non-zero, a message is delivered to the mailbox above when received aggregate reports show a spike in messages failing DMARC: at least this percentage of messages in reports of the past two days failed, and that rate is at least twice the rate of the preceding week. At most one alert is sent per day. Reports must cover at least 10 messages. Value between 1 and 100."`
+
 	ParsedLocalpart smtp.Localpart `sconf:"-"` // Lower-case if case-sensitivity is not configured for domain. Not "canonical" for catchall separators for backwards compatibility.
 	DNSDomain       dns.Domain     `sconf:"-"` // Effective domain, always set based on Domain field or Domain where this is configured.
 }
@@ -351,6 +655,17 @@ type TLSRPT struct {
 	DNSDomain       dns.Domain     `sconf:"-"` // Effective domain, always set based on Domain field or Domain where this is configured.
 }
 
+// BIMI configures the logo published for a domain with BIMI.
+type BIMI struct {
+	Selector      string `sconf:"optional" sconf-doc:"Selector of the BIMI DNS record, published at <selector>._bimi.<domain>. Default is \"default\", which receiving mail servers use for messages without BIMI-Selector header."`
+	LogoFile      string `sconf:"optional" sconf-doc:"File with the logo, in the SVG Tiny Portable/Secure format required by BIMI, at most 32KB. Relative paths are relative to the config directory. Served at https://bimi.<domain>/.well-known/bimi/<selector>.svg by listeners with BIMIHTTPS enabled. Either LogoFile or LogoURL must be set."`
+	LogoURL       string `sconf:"optional" sconf-doc:"HTTPS URL of the logo, when hosted elsewhere instead of by mox."`
+	AuthorityFile string `sconf:"optional" sconf-doc:"File with the Verified Mark Certificate (VMC) for the logo in PEM format, served at https://bimi.<domain>/.well-known/bimi/<selector>.pem by listeners with BIMIHTTPS enabled. Some receiving mail servers only show logos with a VMC. Relative paths are relative to the config directory."`
+	AuthorityURL  string `sconf:"optional" sconf-doc:"HTTPS URL of the VMC, when hosted elsewhere instead of by mox."`
+
+	SelectorEffective string `sconf:"-" json:"-"` // Selector, or "default".
+}
+
 type Canonicalization struct {
 	HeaderRelaxed bool `sconf-doc:"If set, some modifications to the headers (mostly whitespace) are allowed."`
 	BodyRelaxed   bool `sconf-doc:"If set, some whitespace modifications to the message body are allowed."`
@@ -372,9 +687,82 @@ type Selector struct {
 	Domain            dns.Domain    `sconf:"-" json:"-"` // Of selector only, not FQDN.
 }
 
+// DomainSignup configures self-service signup for accounts at a domain.
+type DomainSignup struct {
+	Open             bool  `sconf:"optional" sconf-doc:"Allow signups without invitation. By default, an invitation created by an admin is required."`
+	RequireApproval  bool  `sconf:"optional" sconf-doc:"After verification of the contact email address, an admin must approve the signup before the account is created."`
+	QuotaMessageSize int64 `sconf:"optional" sconf-doc:"Maximum total message size in bytes for accounts created through signup. If zero, the default for the domain applies."`
+}
+
+// Features of the web interfaces that can be disabled per domain.
+const (
+	WebFeatureImport     = "import"     // Importing messages in the account web interface.
+	WebFeatureExport     = "export"     // Exporting messages in the webmail and account web interfaces.
+	WebFeatureWebhooks   = "webhooks"   // Configuring webhooks for outgoing and incoming messages.
+	WebFeaturePGP        = "pgp"        // Managing OpenPGP keys.
+	WebFeatureTLSPubKeys = "tlspubkeys" // Managing TLS public keys for client certificate authentication.
+)
+
+// WebFeatures are the features that can be listed in DomainWeb.DisabledFeatures.
+var WebFeatures = []string{WebFeatureImport, WebFeatureExport, WebFeatureWebhooks, WebFeaturePGP, WebFeatureTLSPubKeys}
+
+// DomainWeb customizes the webmail and account web interfaces for a domain.
+type DomainWeb struct {
+	Name             string           `sconf:"optional" sconf-doc:"Name of the service, shown in page titles and on the login page instead of \"Mox Webmail\" and \"Mox Account\"."`
+	LogoFile         string           `sconf:"optional" sconf-doc:"Image file shown on the login page, e.g. PNG, JPEG or SVG. Relative paths are relative to the config directory. Served as branding/logo below the path of the web interfaces."`
+	LoginText        string           `sconf:"optional" sconf-doc:"Text shown on the login page, e.g. with contact information for support."`
+	Color            string           `sconf:"optional" sconf-doc:"CSS color for links and buttons, e.g. #1a73e8."`
+	BackgroundColor  string           `sconf:"optional" sconf-doc:"CSS color for the page background."`
+	CSSFile          string           `sconf:"optional" sconf-doc:"File with additional CSS, like webmail.css and webaccount.css in the config directory but only for this domain, and included for both interfaces. Relative paths are relative to the config directory."`
+	WebmailDefaults  *WebmailDefaults `sconf:"optional" sconf-doc:"Initial webmail settings for new accounts. Existing accounts keep their settings."`
+	DisabledFeatures []string         `sconf:"optional" sconf-doc:"Features of the web interfaces that cannot be used by accounts, and that are not shown. Valid values: import, export, webhooks, pgp, tlspubkeys."`
+}
+
+// WebmailDefaults are the initial webmail settings for new accounts.
+type WebmailDefaults struct {
+	ShowHTML            bool `sconf:"optional" sconf-doc:"Show the HTML version of messages by default, instead of the plain text version."`
+	ShowAddressSecurity bool `sconf:"optional" sconf-doc:"Show bars underneath address input fields indicating security support of the recipient domain."`
+	NoShowShortcuts     bool `sconf:"optional" sconf-doc:"Don't show keyboard shortcuts after mouse interaction."`
+	RemoteContentProxy  bool `sconf:"optional" sconf-doc:"Load remote content in HTML messages through a proxy in mox."`
+	UndoSendSeconds     int  `sconf:"optional" sconf-doc:"Number of seconds, between 5 and 30, that sent messages are held before delivery, during which sending can be undone. Zero disables."`
+}
+
+// DNSProvisioning configures managing DNS records of a domain through a DNS
+// provider.
+type DNSProvisioning struct {
+	Provider     string        `sconf-doc:"Name of DNS provider in mox.conf."`
+	Zone         string        `sconf:"optional" sconf-doc:"DNS zone the records for the domain are in, if not the domain itself, e.g. for a subdomain."`
+	TTL          int           `sconf:"optional" sconf-doc:"Time to live in seconds for records. Default 300."`
+	DKIMRotation *DKIMRotation `sconf:"optional" sconf-doc:"If set, DKIM keys for signing are periodically replaced with new keys. New selectors are first published in DNS while still signing with the old keys. After a delay, messages are signed with the new keys. Old keys stay published for a while, so messages in transit can still be verified, before they are removed."`
+
+	DNSZone dns.Domain `sconf:"-" json:"-"`
+}
+
+// DKIMRotation configures automatic rotation of DKIM keys.
+type DKIMRotation struct {
+	Interval     time.Duration `sconf:"optional" sconf-doc:"Period between rotations, starting at the previous rotation. Default 2160h (90 days)."`
+	PublishDelay time.Duration `sconf:"optional" sconf-doc:"Period new selectors are published in DNS before signing with them, so they have propagated. Default 48h."`
+	RetainPeriod time.Duration `sconf:"optional" sconf-doc:"Period old selectors remain published in DNS after signing has switched to new selectors, for verifying messages in transit. Default 168h (7 days)."`
+}
+
 type DKIM struct {
 	Selectors map[string]Selector `sconf-doc:"Emails can be DKIM signed. Config parameters are per selector. A DNS record must be created for each selector. Add the name to Sign to use the selector for signing messages."`
 	Sign      []string            `sconf:"optional" sconf-doc:"List of selectors that emails will be signed with."`
+	SignRules []DKIMSignRule      `sconf:"optional" sconf-doc:"Rules for selecting the selectors to sign an outgoing message with, instead of the selectors in Sign. Rules are evaluated in order, the first matching rule determines the selectors. If no rule matches, the selectors in Sign are used. A message can be signed with multiple selectors, e.g. one with an RSA and one with an ed25519 key, each with their own canonicalization and headers."`
+	Rotation  *DKIMRotation       `sconf:"optional" sconf-doc:"If set, DKIM keys for signing are periodically replaced with new keys, also for domains without DNS provisioning. The DNS records for new selectors must then be added manually, they are listed as tasks in the admin web interface. Signing switches to the new keys only after their DNS records are found in DNS. Old selectors are removed from the configuration after the retain period, their DNS records should then be removed manually. For domains with DNS provisioning, the DKIMRotation setting in DNSProvisioning can be used instead, not both."`
+}
+
+// DKIMSignRule selects the DKIM selectors to sign an outgoing message with. All
+// configured match fields must match.
+type DKIMSignRule struct {
+	MsgFromRegexp string            `sconf:"optional" sconf-doc:"Matches if this regular expression matches (a substring of) the address in the message From header. E.g. '^newsletter@example\\.org$'."`
+	HeadersRegexp map[string]string `sconf:"optional" sconf-doc:"Matches if these header field/value regular expressions all match (substrings of) the message headers. Header fields and values are converted to lower case before matching. Whitespace is trimmed from the value before matching. A header field can occur multiple times in a message, only one instance has to match."`
+	Account       string            `sconf:"optional" sconf-doc:"Matches if the message is submitted by this account, through SMTP submission, webmail or the webapi. Messages generated by mox itself, such as DSNs and reports, never match."`
+	Sign          []string          `sconf-doc:"Selectors to sign the message with if this rule matches."`
+	Comment       string            `sconf:"optional" sconf-doc:"Free-form comments."`
+
+	MsgFromRegexpCompiled *regexp.Regexp      `sconf:"-" json:"-"`
+	HeadersRegexpCompiled [][2]*regexp.Regexp `sconf:"-" json:"-"`
 }
 
 type Route struct {
@@ -390,6 +778,80 @@ type Route struct {
 	ResolvedTransport Transport `sconf:"-" json:"-"`
 }
 
+// OutgoingFooter is text added to the end of the message text of outgoing
+// messages. It is added to text/plain and text/html parts that form the message
+// text, not to attachments. Messages that are signed or encrypted, e.g. with
+// S/MIME or PGP/MIME, are left unchanged, the footer would invalidate the
+// signature.
+type OutgoingFooter struct {
+	Text []string `sconf-doc:"Lines of text for the footer, added to text/plain parts. Can contain non-ASCII characters."`
+	HTML string   `sconf:"optional" sconf-doc:"HTML for the footer, added to text/html parts before the closing body tag. If empty, the HTML footer is generated from the text lines."`
+}
+
+// MessageTemplate is a template for composing a message in the webmail. The
+// subject and text can contain placeholders that are replaced when the template
+// is used: {{recipient.name}}, {{recipient.firstname}}, {{recipient.email}},
+// {{sender.name}}, {{sender.email}} and {{date}}.
+type MessageTemplate struct {
+	Name    string   `sconf-doc:"Name of the template, unique within the domain."`
+	Subject string   `sconf:"optional" sconf-doc:"Subject for the message. If empty, the subject in the compose window is left as is."`
+	Text    []string `sconf:"optional" sconf-doc:"Lines of text for the message body. Can contain non-ASCII characters."`
+}
+
+// Identity is an address with display name and signature an account can send
+// messages as in the webmail.
+type Identity struct {
+	Address       string   `sconf-doc:"Email address for the message From header. Must be an address the account is allowed to send as."`
+	DisplayName   string   `sconf:"optional" sconf-doc:"Display name for the message From header. If empty, the full name of the account or destination is used."`
+	Signature     []string `sconf:"optional" sconf-doc:"Lines of text for the signature, added to new messages and replies composed with this identity."`
+	SignatureHTML string   `sconf:"optional" sconf-doc:"HTML for the signature. If set, messages sent with this identity get an HTML version of the text with this signature, in addition to the plain text version."`
+	ReplyQuoting  string   `sconf:"optional" sconf-doc:"How to quote the message when replying with this identity: empty for the account default, \"bottom\" to reply below the quoted text, or \"top\" to reply above the quoted text."`
+	ReplyTo       string   `sconf:"optional" sconf-doc:"Default Reply-To address for messages composed with this identity."`
+	Default       bool     `sconf:"optional" sconf-doc:"Whether this identity is selected by default when composing new messages. At most one identity can be the default."`
+}
+
+// Delegation gives another account access to mailboxes of an account.
+type Delegation struct {
+	Account   string   `sconf-doc:"Account that is given access."`
+	Mailboxes []string `sconf-doc:"Names of mailboxes the account is given access to. Child mailboxes are not included. Access is by name: if a mailbox is renamed, access to it ends until the delegation is updated with the new name, and a new mailbox created with the old name is accessible."`
+	Write     bool     `sconf:"optional" sconf-doc:"If set, the account can also mark messages as (un)read, move messages between the mailboxes and delete messages. Otherwise access is read-only."`
+	Send      string   `sconf:"optional" sconf-doc:"Whether the account can send messages with the addresses of this account as message From address: empty for not allowed, \"as\" to send as this account, or \"onbehalf\" to send on behalf of this account, with a Sender header with the address of the account sending the message."`
+}
+
+// OutgoingHeaderRules are changes to the message header of outgoing messages.
+// Removals are done first, then From and Reply-To changes, then additions.
+type OutgoingHeaderRules struct {
+	Remove          []string `sconf:"optional" sconf-doc:"Keys of header fields to remove, case-insensitive. A trailing \"*\" matches any suffix, e.g. \"X-Internal-*\"."`
+	FromDisplayName string   `sconf:"optional" sconf-doc:"If set, the display name in the From header is replaced with this value. The text \"{name}\" is replaced with the original display name, e.g. \"{name} (Example Corp)\"."`
+	ReplyTo         string   `sconf:"optional" sconf-doc:"If set, the Reply-To header is set to this address, replacing any Reply-To header in the message. E.g. \"Support <support@example.com>\"."`
+	Add             []string `sconf:"optional" sconf-doc:"Header fields to add, in the form \"Key: value\", e.g. an organization disclaimer. Existing header fields with the same key are removed. Non-ASCII text is encoded."`
+}
+
+// RetrySchedule specifies the intervals between delivery attempts for matching
+// messages in the queue, and when to give up.
+type RetrySchedule struct {
+	ToDomain    []string        `sconf:"optional" sconf-doc:"Matches if the envelope to domain matches one of the configured domains, or if the list is empty. If a domain starts with a dot, prefixes of the domain also match."`
+	Class       string          `sconf:"optional" sconf-doc:"Matches if empty or if the message is of this class: \"regular\" for all messages except reports, \"report\" for outgoing DMARC and TLS reports."`
+	MinPriority int             `sconf:"optional" sconf-doc:"Matches if zero or if the priority of the message, from -9 to 9 with 0 for normal priority, e.g. set with the SMTP MT-PRIORITY extension, is at least this value. For example, set to 1 for a schedule for high priority messages."`
+	MaxPriority int             `sconf:"optional" sconf-doc:"Matches if zero or if the priority of the message is at most this value. For example, set to -1 for a schedule for low priority messages."`
+	Intervals   []time.Duration `sconf:"optional" sconf-doc:"Time to wait after a failed delivery attempt before the next attempt, for each attempt. The last interval is used for any further attempts. Default is 7m30s, doubled after each attempt. A small random jitter is added to each interval."`
+	MaxAttempts int             `sconf:"optional" sconf-doc:"Number of delivery attempts after which delivery fails permanently and a DSN is sent to the sender. Default is 8. A maximum number of attempts set explicitly for a message, e.g. through the webapi, takes precedence."`
+	MaxAge      time.Duration   `sconf:"optional" sconf-doc:"If non-zero, delivery fails permanently once the message has been in the queue for this long, even when not all attempts have been made. The last attempt is scheduled at the moment the message reaches this age."`
+
+	ToDomainASCII []string `sconf:"-"`
+}
+
+// TLSPolicy specifies TLS requirements for direct delivery to matching recipient
+// domains.
+type TLSPolicy struct {
+	ToDomain          []string `sconf-doc:"Matches if the envelope to domain matches one of the configured domains. If a domain starts with a dot, prefixes of the domain also match."`
+	Mode              string   `sconf:"optional" sconf-doc:"Empty to keep the default behaviour. \"verify\" requires STARTTLS with a certificate that is valid for the host name according to the WebPKI (in addition to DANE, if present), regardless of MTA-STS. \"required\" requires STARTTLS without verifying the certificate. \"verify\" and \"required\" also apply to messages with a \"TLS-Required: No\" header. \"optional\" allows fallback to plain text and ignores failing MTA-STS and DANE verification, like a \"TLS-Required: No\" header, but not for messages sent with REQUIRETLS."`
+	CertificateSHA256 []string `sconf:"optional" sconf-doc:"If non-empty, the TLS certificate of the remote server must have one of these SHA-256 fingerprints, of the DER-encoded leaf certificate, in hexadecimal, optionally with colons. Implies STARTTLS is required. Cannot be combined with mode \"optional\"."`
+
+	ToDomainASCII           []string `sconf:"-"`
+	CertificateSHA256Parsed [][]byte `sconf:"-" json:"-"`
+}
+
 // todo: move RejectsMailbox to store.Mailbox.SpecialUse, possibly with "X" prefix?
 
 // note: outgoing hook events are in ../queue/hooks.go, ../mox-/config.go, ../queue.go and ../webapi/gendoc.sh. keep in sync.
@@ -398,11 +860,14 @@ type OutgoingWebhook struct {
 	URL           string   `sconf-doc:"URL to POST webhooks."`
 	Authorization string   `sconf:"optional" sconf-doc:"If not empty, value of Authorization header to add to HTTP requests."`
 	Events        []string `sconf:"optional" sconf-doc:"Events to send outgoing delivery notifications for. If absent, all events are sent. Valid values: delivered, suppressed, delayed, failed, relayed, expanded, canceled, unrecognized."`
+	SigningKey    string   `sconf:"optional" sconf-doc:"If not empty, HTTP requests are signed with HMAC-SHA256 over the request body with this key. The signature is added as hex-encoded value to header X-Mox-Webhook-Signature, prefixed with \"sha256=\"."`
+	Transcript    bool     `sconf:"optional" sconf-doc:"If set, the SMTP protocol transcript of the last delivery attempt is included in webhooks about deliveries, without authentication and message data. Transcripts can be large."`
 }
 
 type IncomingWebhook struct {
 	URL           string `sconf-doc:"URL to POST webhooks to for incoming deliveries over SMTP."`
 	Authorization string `sconf:"optional" sconf-doc:"If not empty, value of Authorization header to add to HTTP requests."`
+	SigningKey    string `sconf:"optional" sconf-doc:"If not empty, HTTP requests are signed with HMAC-SHA256 over the request body with this key. The signature is added as hex-encoded value to header X-Mox-Webhook-Signature, prefixed with \"sha256=\"."`
 }
 
 type SubjectPass struct {
@@ -429,16 +894,25 @@ type Account struct {
 	FullName                     string                 `sconf:"optional" sconf-doc:"Full name, to use in message From header when composing messages in webmail. Can be overridden per destination."`
 	Destinations                 map[string]Destination `sconf:"optional" sconf-doc:"Destinations, keys are email addresses (with IDNA domains). All destinations are allowed for logging in with IMAP/SMTP/webmail. If no destinations are configured, the account can not login. If the address is of the form '@domain', i.e. with localpart missing, it serves as a catchall for the domain, matching all messages that are not explicitly configured. Deprecated behaviour: If the address is not a full address but a localpart, it is combined with Domain to form a full address."`
 	SubjectPass                  SubjectPass            `sconf:"optional" sconf-doc:"If configured, messages classified as weakly spam are rejected with instructions to retry delivery, but this time with a signed token added to the subject. During the next delivery attempt, the signed token will bypass the spam filter. Messages with a clear spam signal, such as a known bad reputation, are rejected/delayed without a signed token."`
-	QuotaMessageSize             int64                  `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for the account, overriding any globally configured default maximum size if non-zero. A negative value can be used to have no limit in case there is a limit by default. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage."`
+	QuotaMessageSize             int64                  `sconf:"optional" sconf-doc:"Default maximum total message size in bytes for the account, overriding any default maximum size configured for the domain of the account or globally if non-zero. A negative value can be used to have no limit in case there is a limit by default. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage."`
 	RejectsMailbox               string                 `sconf:"optional" sconf-doc:"Mail that looks like spam will be rejected, but a copy can be stored temporarily in a mailbox, e.g. Rejects. If mail isn't coming in when you expect, you can look there. The mail still isn't accepted, so the remote mail server may retry (hopefully, if legitimate), or give up (hopefully, if indeed a spammer). Messages are automatically removed from this mailbox, so do not set it to a mailbox that has messages you want to keep."`
 	KeepRejects                  bool                   `sconf:"optional" sconf-doc:"Don't automatically delete mail in the RejectsMailbox listed above. This can be useful, e.g. for future spam training. It can also cause storage to fill up."`
 	AutomaticJunkFlags           AutomaticJunkFlags     `sconf:"optional" sconf-doc:"Automatically set $Junk and $NotJunk flags based on mailbox messages are delivered/moved/copied to. Email clients typically have too limited functionality to conveniently set these flags, especially $NonJunk, but they can all move messages to a different mailbox, so this helps them."`
 	JunkFilter                   *JunkFilter            `sconf:"optional" sconf-doc:"Content-based filtering, using the junk-status of individual messages to rank words in such messages as spam or ham. It is recommended you always set the applicable (non)-junk status on messages, and that you do not empty your Trash because those messages contain valuable ham/spam training information."` // todo: sane defaults for junkfilter
+	JunkReevaluation             *JunkReevaluation      `sconf:"optional" sconf-doc:"Re-evaluate recently received messages when new information about them becomes available after delivery, moving them from the Inbox to the Junk mailbox, or back. Only messages that are still in the mailbox they were delivered to are moved. The Junk mailbox is the mailbox with the Junk special-use role."`
+	Screening                    *Screening             `sconf:"optional" sconf-doc:"If set, messages from senders that have not been seen before are delivered to a screening mailbox instead of the Inbox, until the sender is approved by moving one of their messages out of the screening mailbox (to a mailbox other than Junk or Trash), which adds the sender to the allow list. Senders on the block list are rejected. Senders are known if they are on the allow list and the message From address is verified, or if this account has sent them a message, or has received a message with a verified From address from them outside the screening mailbox and not marked as junk. Forwarded and mailing list messages, DSNs and messages delivered through rulesets are not screened. The allow and block lists are managed in the account web interface."`
+	Categories                   *Categories            `sconf:"optional" sconf-doc:"If set, incoming bulk messages that are not spam ('graymail'), such as newsletters, automated notifications and updates from social networks, are classified and delivered to separate mailboxes and/or marked with a keyword: $newsletter, $notification or $social. Classification is independent of junk filtering: messages classified as junk are still delivered to the Junk mailbox or rejected. Only messages that would be delivered to the default mailbox of the destination are categorized, not messages matched by rulesets, delivered to the screening mailbox, or to reporting addresses."`
 	MaxOutgoingMessagesPerDay    int                    `sconf:"optional" sconf-doc:"Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000."`
 	MaxFirstTimeRecipientsPerDay int                    `sconf:"optional" sconf-doc:"Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200."`
 	NoFirstTimeSenderDelay       bool                   `sconf:"optional" sconf-doc:"Do not apply a delay to SMTP connections before accepting an incoming message from a first-time sender. Can be useful for accounts that sends automated responses and want instant replies."`
 	NoCustomPassword             bool                   `sconf:"optional" sconf-doc:"If set, this account cannot set a password of their own choice, but can only set a new randomly generated password, preventing password reuse across services and use of weak passwords. Custom account passwords can be set by the admin."`
+	PasswordPolicy               *PasswordPolicy        `sconf:"optional" sconf-doc:"Requirements for passwords set by the account, password expiry, and lockout after repeated failed login attempts."`
+	RequireTwoFactor             bool                   `sconf:"optional" sconf-doc:"If set, logins to the web interfaces require a second factor, a time-based one-time password (TOTP) from an authenticator app. Until the account has configured TOTP, it can only login to the account web interface to set it up. IMAP/SMTP clients and other non-web protocols must use app passwords instead of the account password. Also required for accounts of a domain that requires two-factor authentication."`
 	Routes                       []Route                `sconf:"optional" sconf-doc:"Routes for delivering outgoing messages through the queue. Each delivery attempt evaluates these account routes, domain routes and finally global routes. The transport of the first matching route is used in the delivery attempt. If no routes match, which is the default with no configured routes, messages are delivered directly from the queue."`
+	OutgoingHeaderRules          *OutgoingHeaderRules   `sconf:"optional" sconf-doc:"Changes to the message header of outgoing messages submitted by this account, made during submission before DKIM signing. Applied after rules of the domain of the message From address."`
+	OutgoingFooter               *OutgoingFooter        `sconf:"optional" sconf-doc:"Footer, e.g. a disclaimer, added to the text of outgoing messages submitted by this account. Takes precedence over a footer of the domain of the message From address."`
+	Identities                   []Identity             `sconf:"optional" sconf-doc:"Identities for sending messages with webmail, each with a From address, display name and signature. The addresses must be allowed as message From address for the account. If no identity is configured, the account addresses are used with the account signature."`
+	Delegations                  []Delegation           `sconf:"optional" sconf-doc:"Access to mailboxes of this account given to other accounts, e.g. for sharing a support mailbox within a team. The other accounts can open the mailboxes in the webmail, and possibly send messages with the addresses of this account."`
 
 	DNSDomain                  dns.Domain     `sconf:"-"` // Parsed form of Domain.
 	JunkMailbox                *regexp.Regexp `sconf:"-" json:"-"`
@@ -446,16 +920,103 @@ type Account struct {
 	NotJunkMailbox             *regexp.Regexp `sconf:"-" json:"-"`
 	ParsedFromIDLoginAddresses []smtp.Address `sconf:"-" json:"-"`
 	Aliases                    []AddressAlias `sconf:"-"`
+	OwnedAliases               []string       `sconf:"-"` // Addresses of aliases with an address of this account as owner.
+}
+
+type PasswordPolicy struct {
+	MinLength       int           `sconf:"optional" sconf-doc:"Minimum number of characters for passwords the account sets through the account web interface. Passwords are always at least 8 characters."`
+	MinEntropy      int           `sconf:"optional" sconf-doc:"Minimum estimated strength in bits of passwords the account sets through the account web interface, based on the length and the kinds of characters. E.g. 50. Randomly generated passwords have around 75 bits."`
+	MaxAge          time.Duration `sconf:"optional" sconf-doc:"If non-zero, passwords expire after this period, and login attempts with an expired password are rejected on all protocols except the account web interface, where a new password can be set. E.g. 2160h (90 days). Passwords set with older versions of mox have no known age and don't expire automatically, but the admin can require a password change."`
+	LockoutFailures int           `sconf:"optional" sconf-doc:"If non-zero, after this many consecutive failed login attempts for the account, on any protocol, login attempts are rejected during LockoutPeriod, even with the correct password."`
+	LockoutPeriod   time.Duration `sconf:"optional" sconf-doc:"Period during which login attempts are rejected after LockoutFailures failed attempts. Default 15m."`
 }
 
 type AddressAlias struct {
 	SubscriptionAddress string
-	Alias               Alias    // Without members.
-	MemberAddresses     []string // Only if allowed to see.
+	Alias               Alias       // Without members.
+	MemberAddresses     []string    // Only if allowed to see.
+	Member              AliasMember // Delivery preferences of the subscription address.
+}
+
+type JunkReevaluation struct {
+	Window   time.Duration `sconf:"optional" sconf-doc:"Only messages received within this period are re-evaluated. Default 72h."`
+	Sender   bool          `sconf:"optional" sconf-doc:"When a message is marked as junk through webmail or the webapi, by moving it to the Junk mailbox or by setting the $Junk flag, also move other messages in the Inbox from the same verified message From address to the Junk mailbox. Messages that were moved to the Inbox by the user, or that are marked as not junk, are not moved. Marking a message as not junk moves messages from the same verified sender from the Junk mailbox to the Inbox."`
+	DNSBL    bool          `sconf:"optional" sconf-doc:"Periodically check the remote IPs that messages in the Inbox were delivered from against the DNSBLs configured in the SMTP listeners. Messages from IPs that are now listed are moved to the Junk mailbox, unless the junk filter, if configured, classifies them as ham, with a spaminess below 0.5."`
+	Spamtrap bool          `sconf:"optional" sconf-doc:"When a message is sent to a spamtrap address, see Spamtraps in the domain configuration, move messages in the Inbox from the same remote IP, or from the same verified message From organizational domain, to the Junk mailbox. Like training from spamtraps, nothing is moved for senders with an allow override or with messages marked as not junk by users, and only for the first messages of a sending IP to spamtraps. Messages that were moved to the Inbox by the user, or that are marked as junk or not junk, are not moved."`
+	Notify   bool          `sconf:"optional" sconf-doc:"Deliver a message to the Inbox listing the messages that were moved."`
+}
+
+type Screening struct {
+	Mailbox string `sconf:"optional" sconf-doc:"Mailbox for messages from first-time senders awaiting approval, created when needed. Default: Screening."`
+}
+
+// MailboxName returns the configured screening mailbox, or the default
+// "Screening".
+func (s Screening) MailboxName() string {
+	if s.Mailbox == "" {
+		return "Screening"
+	}
+	return s.Mailbox
+}
+
+// Categories configures classification of incoming bulk messages. A nil
+// category is not used.
+type Categories struct {
+	Newsletters   *Category `sconf:"optional" sconf-doc:"Newsletters, marketing and mailing list messages, recognized by a List-Id or List-Unsubscribe header, or a Precedence header with value bulk or list."`
+	Notifications *Category `sconf:"optional" sconf-doc:"Automated messages like receipts, alerts and account notifications, recognized by an Auto-Submitted header (other than \"no\"), also when the message has mailing list headers, or by a sender address without mailing list headers with a localpart like noreply, no-reply, donotreply, notifications or alerts."`
+	Social        *Category `sconf:"optional" sconf-doc:"Updates from social networks, recognized by the sender domain only. Messages from the following domains (and subdomains) are in this category when no Domains are configured: facebookmail.com, instagram.com, linkedin.com, pinterest.com, reddit.com, tiktok.com, twitter.com, x.com."`
+}
+
+// Category is a class of bulk messages, see Categories.
+type Category struct {
+	Mailbox string   `sconf:"optional" sconf-doc:"Mailbox to deliver messages in this category to, created when needed. If empty, messages are delivered to the default mailbox, only marked with the keyword of the category."`
+	Domains []string `sconf:"optional" sconf-doc:"Sender domains whose messages are always in this category, also matching their subdomains, regardless of message headers. Only if the domain is verified, through the DMARC-aligned message From address, or a DKIM signature. Domains take precedence over the header-based heuristics of all categories. E.g. github.com for notifications."`
+
+	DNSDomains []dns.Domain `sconf:"-" json:"-"`
 }
 
 type JunkFilter struct {
-	Threshold float64 `sconf-doc:"Approximate spaminess score between 0 and 1 above which emails are rejected as spam. Each delivery attempt adds a little noise to make it slightly harder for spammers to identify words that strongly indicate non-spaminess and use it to bypass the filter. E.g. 0.95."`
+	Threshold   float64 `sconf-doc:"Approximate spaminess score between 0 and 1 above which emails are rejected as spam. Each delivery attempt adds a little noise to make it slightly harder for spammers to identify words that strongly indicate non-spaminess and use it to bypass the filter. E.g. 0.95."`
+	SpamActions `sconf:"optional" sconf-doc:"Tiered actions based on the spaminess score, e.g. tagging or delivering to the Junk mailbox below Threshold, and quarantining above it. Unset fields are taken from SpamActions of the default domain of the account."`
+	junk.Params
+}
+
+const (
+	SpamHighActionReject     = "reject"     // Reject during SMTP transaction, storing a copy in the rejects mailbox.
+	SpamHighActionQuarantine = "quarantine" // Accept, but only store in the rejects mailbox.
+)
+
+// SpamActions configures tiered actions for incoming messages based on the
+// spaminess score of the junk filter. Zero values are unset.
+type SpamActions struct {
+	TagThreshold  float64 `sconf:"optional" sconf-doc:"Spaminess score between 0 and 1 above which accepted messages get X-Mox-Spam-Flag and X-Mox-Spam-Score headers, so mail clients can filter on them. E.g. 0.5. Zero for no tagging."`
+	JunkThreshold float64 `sconf:"optional" sconf-doc:"Spaminess score between 0 and 1 above which messages are accepted but delivered to the Junk mailbox of the account. Should be below Threshold. If the account has no mailbox with the Junk special-use role, messages are delivered normally. E.g. 0.8. Zero to not deliver to Junk based on score."`
+	HighAction    string  `sconf:"optional" sconf-doc:"Action for messages with a spaminess score above Threshold: reject (the message is rejected during the SMTP transaction, with a copy stored in the rejects mailbox if configured) or quarantine (the message is accepted, so the sender does not learn it was considered spam, but only stored in the rejects mailbox, which must be configured). Default: reject."`
+}
+
+// EffectiveSpamActions returns the spam actions of the junk filter, with unset
+// fields taken from domainActions, e.g. from the default domain of the account.
+func (jf JunkFilter) EffectiveSpamActions(domainActions *SpamActions) SpamActions {
+	sa := jf.SpamActions
+	if domainActions != nil {
+		if sa.TagThreshold == 0 {
+			sa.TagThreshold = domainActions.TagThreshold
+		}
+		if sa.JunkThreshold == 0 {
+			sa.JunkThreshold = domainActions.JunkThreshold
+		}
+		if sa.HighAction == "" {
+			sa.HighAction = domainActions.HighAction
+		}
+	}
+	if sa.HighAction == "" {
+		sa.HighAction = SpamHighActionReject
+	}
+	return sa
+}
+
+type SharedJunkFilter struct {
+	Weight float64 `sconf-doc:"Weight between 0 and 1 of the spaminess score of the shared junk filter when combined with the score of the per-account junk filter. If only one of the two filters has enough words for a significant score, that score is used. E.g. 0.3."`
 	junk.Params
 }
 
@@ -526,11 +1087,29 @@ type KeyCert struct {
 	KeyFile  string `sconf-doc:"Private key for certificate, in PEM format. PKCS8 is recommended, but PKCS1 and EC private keys are recognized as well."`
 }
 
+// CertSource is a source of keys and certificates. Exactly one field must be set.
+type CertSource struct {
+	Directory string           `sconf:"optional" sconf-doc:"Directory with keys and certificates in PEM format, evaluated relative to the directory of mox.conf. Recognized are pairs of files <name>.crt and <name>.key, as written by e.g. lego, and subdirectories with files fullchain.pem and privkey.pem, as written by e.g. certbot in /etc/letsencrypt/live. Other files are ignored. The files are read by the unprivileged mox process, so must be readable by it."`
+	Vault     *CertSourceVault `sconf:"optional" sconf-doc:"Keys and certificates stored in secrets in HashiCorp Vault."`
+}
+
+// CertSourceVault fetches keys and certificates from key/value secrets in Vault.
+type CertSourceVault struct {
+	Address          string   `sconf-doc:"Address of Vault, e.g. https://vault.example.com:8200."`
+	TokenFile        string   `sconf-doc:"File with the token for Vault, evaluated relative to the directory of mox.conf. Read once at startup."`
+	Paths            []string `sconf-doc:"Paths of key/value secrets, each with a key and certificate, e.g. secret/data/mox/mail.example.com for a version 2 key/value secrets engine mounted at secret."`
+	CertificateField string   `sconf:"optional" sconf-doc:"Field in the secret with the certificate, including intermediate CA certificates, in PEM format. Default certificate."`
+	PrivateKeyField  string   `sconf:"optional" sconf-doc:"Field in the secret with the private key in PEM format. Default private_key."`
+
+	Token string `sconf:"-" json:"-"`
+}
+
 type TLS struct {
-	ACME                string    `sconf:"optional" sconf-doc:"Name of provider from top-level configuration to use for ACME, e.g. letsencrypt."`
-	KeyCerts            []KeyCert `sconf:"optional" sconf-doc:"Keys and certificates to use for this listener. The files are opened by the privileged root process and passed to the unprivileged mox process, so no special permissions are required on the files. If the private key will not be replaced when refreshing certificates, also consider adding the private key to HostPrivateKeyFiles and configuring DANE TLSA DNS records."`
-	MinVersion          string    `sconf:"optional" sconf-doc:"Minimum TLS version. Default: TLSv1.2."`
-	HostPrivateKeyFiles []string  `sconf:"optional" sconf-doc:"Private keys used for ACME certificates. Specified explicitly so DANE TLSA DNS records can be generated, even before the certificates are requested. DANE is a mechanism to authenticate remote TLS certificates based on a public key or certificate specified in DNS, protected with DNSSEC. DANE is opportunistic and attempted when delivering SMTP with STARTTLS. The private key files must be in PEM format. PKCS8 is recommended, but PKCS1 and EC private keys are recognized as well. Only RSA 2048 bit and ECDSA P-256 keys are currently used. The first of each is used when requesting new certificates through ACME."`
+	ACME                string       `sconf:"optional" sconf-doc:"Name of provider from top-level configuration to use for ACME, e.g. letsencrypt."`
+	KeyCerts            []KeyCert    `sconf:"optional" sconf-doc:"Keys and certificates to use for this listener. The files are opened by the privileged root process and passed to the unprivileged mox process, so no special permissions are required on the files. If the private key will not be replaced when refreshing certificates, also consider adding the private key to HostPrivateKeyFiles and configuring DANE TLSA DNS records."`
+	CertSources         []CertSource `sconf:"optional" sconf-doc:"Additional sources of keys and certificates for this listener, for certificates managed outside of mox, e.g. by an external ACME client or in Vault. Certificates from KeyCerts and CertSources are reloaded on SIGHUP and through the admin API. During a reload, KeyCerts files are read again by the unprivileged mox process if it has permission, otherwise the certificates loaded at startup are kept. If a reload fails, the previous certificates stay in use. Connections get the first certificate that matches the requested hostname, or the first certificate if none matches."`
+	MinVersion          string       `sconf:"optional" sconf-doc:"Minimum TLS version. Default: TLSv1.2."`
+	HostPrivateKeyFiles []string     `sconf:"optional" sconf-doc:"Private keys used for ACME certificates. Specified explicitly so DANE TLSA DNS records can be generated, even before the certificates are requested. DANE is a mechanism to authenticate remote TLS certificates based on a public key or certificate specified in DNS, protected with DNSSEC. DANE is opportunistic and attempted when delivering SMTP with STARTTLS. The private key files must be in PEM format. PKCS8 is recommended, but PKCS1 and EC private keys are recognized as well. Only RSA 2048 bit and ECDSA P-256 keys are currently used. The first of each is used when requesting new certificates through ACME."`
 
 	Config                   *tls.Config     `sconf:"-" json:"-"` // TLS config for non-ACME-verification connections, i.e. SMTP and IMAP, and not port 443. Connections without SNI will use a certificate for the hostname of the listener, connections with an SNI hostname that isn't allowed will be rejected.
 	ConfigFallback           *tls.Config     `sconf:"-" json:"-"` // Like Config, but uses the certificate for the listener hostname when the requested SNI hostname is not allowed, instead of causing the connection to fail.
@@ -141,6 +141,45 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# mox.conf.
 				KeyFile:
 
+			# If set, certificates are requested with DNS-01 challenges, by setting TXT
+			# records through a DNS provider, instead of with tls-alpn-01 or http-01. Hosts
+			# then do not have to be reachable from the internet on port 443 or 80. Also
+			# required for wildcard certificates. (optional)
+			DNS01:
+
+				# Name of DNS provider in DNSProviders, used to set the TXT records at
+				# _acme-challenge.<host>.
+				Provider:
+
+				# DNS zones at the DNS provider. The TXT record for a host is set in the longest
+				# zone that contains the host, e.g. zone example.com for
+				# _acme-challenge.mail.example.com.
+				Zones:
+					-
+
+				# Time to wait after setting a TXT record before asking the ACME provider to
+				# validate it, for the record to reach all authoritative name servers. Default
+				# 30s. (optional)
+				PropagationDelay: 0s
+
+				# Domains for which a wildcard certificate is requested, e.g. example.com for
+				# *.example.com. The wildcard certificate is used for all hosts directly under the
+				# domain, such as mta-sts.example.com and autoconfig.example.com, instead of
+				# requesting a certificate per host. Reduces the number of certificates and ACME
+				# requests for deployments with many domains. (optional)
+				Wildcards:
+					-
+
+			# When requesting a new certificate for a host with HostPrivateKeyFiles, and the
+			# first configured key (used for new certificates) differs from the key of the
+			# current certificate, and the host has DNSSEC-signed DANE TLSA records but none
+			# for the new key, keep using the key of the current certificate for the new
+			# certificate. Prevents breaking incoming deliveries from servers that verify with
+			# DANE when rotating keys before the TLSA records are updated. Without this
+			# option, an error is logged and the new key is used. See the DANE page in the
+			# admin web interface for the current and next TLSA records. (optional)
+			BlockDANERollover: false
+
 	# File containing hash of admin password, for authentication in the web admin
 	# pages (if enabled). (optional)
 	AdminPasswordFile:
@@ -203,6 +242,49 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 						# EC private keys are recognized as well.
 						KeyFile:
 
+				# Additional sources of keys and certificates for this listener, for certificates
+				# managed outside of mox, e.g. by an external ACME client or in Vault.
+				# Certificates from KeyCerts and CertSources are reloaded on SIGHUP and through
+				# the admin API. During a reload, KeyCerts files are read again by the
+				# unprivileged mox process if it has permission, otherwise the certificates loaded
+				# at startup are kept. If a reload fails, the previous certificates stay in use.
+				# Connections get the first certificate that matches the requested hostname, or
+				# the first certificate if none matches. (optional)
+				CertSources:
+					-
+
+						# Directory with keys and certificates in PEM format, evaluated relative to the
+						# directory of mox.conf. Recognized are pairs of files <name>.crt and <name>.key,
+						# as written by e.g. lego, and subdirectories with files fullchain.pem and
+						# privkey.pem, as written by e.g. certbot in /etc/letsencrypt/live. Other files
+						# are ignored. The files are read by the unprivileged mox process, so must be
+						# readable by it. (optional)
+						Directory:
+
+						# Keys and certificates stored in secrets in HashiCorp Vault. (optional)
+						Vault:
+
+							# Address of Vault, e.g. https://vault.example.com:8200.
+							Address:
+
+							# File with the token for Vault, evaluated relative to the directory of mox.conf.
+							# Read once at startup.
+							TokenFile:
+
+							# Paths of key/value secrets, each with a key and certificate, e.g.
+							# secret/data/mox/mail.example.com for a version 2 key/value secrets engine
+							# mounted at secret.
+							Paths:
+								-
+
+							# Field in the secret with the certificate, including intermediate CA
+							# certificates, in PEM format. Default certificate. (optional)
+							CertificateField:
+
+							# Field in the secret with the private key in PEM format. Default private_key.
+							# (optional)
+							PrivateKeyField:
+
 				# Minimum TLS version. Default: TLSv1.2. (optional)
 				MinVersion:
 
@@ -249,10 +331,12 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# accept/reject decision. This prevents sending IPs of all communications to the
 				# block list provider. If any of the listed DNSBLs contains a requested IP
 				# address, the message is rejected as spam. The DNSBLs are checked for healthiness
-				# before use, at most once per 4 hours. IPs we can send from are periodically
-				# checked for being in the configured DNSBLs. See MonitorDNSBLs in domains.conf to
-				# only monitor IPs we send from, without using those DNSBLs for incoming messages.
-				# Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net. See
+				# periodically, lists that fail their health check or that repeatedly time out are
+				# not used until they are healthy again. Lookup results are cached, for 1 hour for
+				# listed IPs and 15 minutes for IPs that are not listed. IPs we can send from are
+				# periodically checked for being in the configured DNSBLs. See MonitorDNSBLs in
+				# domains.conf to only monitor IPs we send from, without using those DNSBLs for
+				# incoming messages. Example DNSBLs: sbl.spamhaus.org, bl.spamcop.net. See
 				# https://www.spamhaus.org/sbl/ and https://www.spamcop.net/ for more information
 				# and terms of use. (optional)
 				DNSBLs:
@@ -266,6 +350,22 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# tickets may work around TLS interoperability issues. (optional)
 				TLSSessionTicketsDisabled: false
 
+				# TLS client fingerprints to reject incoming connections for, in JA3 (32
+				# hexadecimal characters, e.g. 6734f37431670b3ab4292b8f60f29984) or JA4 form (e.g.
+				# t13d1516h2_8daaf6152771_e5627efa2ab1). A fingerprint identifies the TLS
+				# implementation and its configuration of the remote, and can catch botnets that
+				# rotate IPs but keep their TLS stack. Fingerprints of incoming TLS connections
+				# are logged. Connections with a blocked fingerprint are rejected right after the
+				# TLS handshake. (optional)
+				TLSFingerprintsBlocked:
+					-
+
+				# TLS client fingerprints, in the same form as TLSFingerprintsBlocked, for which a
+				# stricter junk filter threshold is applied to incoming messages from senders
+				# without reputation. (optional)
+				TLSFingerprintsSuspicious:
+					-
+
 			# SMTP for submitting email, e.g. by email applications. Starts out in plain text,
 			# can be upgraded to TLS with the STARTTLS command. Prefer using Submissions which
 			# is always a TLS connection. (optional)
@@ -461,6 +561,77 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# limiting and for the "secure" status of cookies. (optional)
 				Forwarded: false
 
+			# Like CalDAVHTTPS, but with plain HTTP, without TLS. (optional)
+			CalDAVHTTP:
+				Enabled: false
+
+				# Default 80 for HTTP and 443 for HTTPS. See Hostname at Listener for hostname
+				# matching behaviour. (optional)
+				Port: 0
+
+				# Path to serve requests on. Should end with a slash, related to cookie paths.
+				# (optional)
+				Path:
+
+				# If set, X-Forwarded-* headers are used for the remote IP address for rate
+				# limiting and for the "secure" status of cookies. (optional)
+				Forwarded: false
+
+			# CalDAV server for synchronizing calendars of accounts with calendar
+			# applications, with HTTPS (requires a TLS config). Meeting invitations delivered
+			# by email are added to the scheduling inbox. Clients can find the server through
+			# /.well-known/caldav. Default path is /caldav/. (optional)
+			CalDAVHTTPS:
+				Enabled: false
+
+				# Default 80 for HTTP and 443 for HTTPS. See Hostname at Listener for hostname
+				# matching behaviour. (optional)
+				Port: 0
+
+				# Path to serve requests on. Should end with a slash, related to cookie paths.
+				# (optional)
+				Path:
+
+				# If set, X-Forwarded-* headers are used for the remote IP address for rate
+				# limiting and for the "secure" status of cookies. (optional)
+				Forwarded: false
+
+			# Like CardDAVHTTPS, but with plain HTTP, without TLS. (optional)
+			CardDAVHTTP:
+				Enabled: false
+
+				# Default 80 for HTTP and 443 for HTTPS. See Hostname at Listener for hostname
+				# matching behaviour. (optional)
+				Port: 0
+
+				# Path to serve requests on. Should end with a slash, related to cookie paths.
+				# (optional)
+				Path:
+
+				# If set, X-Forwarded-* headers are used for the remote IP address for rate
+				# limiting and for the "secure" status of cookies. (optional)
+				Forwarded: false
+
+			# CardDAV server for synchronizing address books of accounts with contact
+			# applications, with HTTPS (requires a TLS config). Addresses that messages are
+			# sent to repeatedly are added to the address book named "collected". Clients can
+			# find the server through /.well-known/carddav. Default path is /carddav/.
+			# (optional)
+			CardDAVHTTPS:
+				Enabled: false
+
+				# Default 80 for HTTP and 443 for HTTPS. See Hostname at Listener for hostname
+				# matching behaviour. (optional)
+				Port: 0
+
+				# Path to serve requests on. Should end with a slash, related to cookie paths.
+				# (optional)
+				Path:
+
+				# If set, X-Forwarded-* headers are used for the remote IP address for rate
+				# limiting and for the "secure" status of cookies. (optional)
+				Forwarded: false
+
 			# Serve prometheus metrics, for monitoring. You should not enable this on a public
 			# IP. (optional)
 			MetricsHTTP:
@@ -507,6 +678,39 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# useful when the mta-sts domain is reverse proxied. (optional)
 				NonTLS: false
 
+			# Serve OpenPGP keys that accounts have published, in the OpenPGP Web Key
+			# Directory (WKD) at openpgpkey.<domain>, for email applications looking up keys
+			# for encrypting messages. A DNS record for openpgpkey.<domain> pointing to this
+			# listener is required. Requires a TLS config. (optional)
+			WKDHTTPS:
+				Enabled: false
+
+				# TLS port, 443 by default. You should only override this if you cannot listen on
+				# port 443 directly. Web Key Directory requests will be made to port 443, so
+				# you'll have to add an external mechanism to get the connection here, e.g. by
+				# configuring port forwarding. (optional)
+				Port: 0
+
+				# If set, plain HTTP instead of HTTPS is spoken on the configured port. Can be
+				# useful when the openpgpkey domain is reverse proxied. (optional)
+				NonTLS: false
+
+			# Serve BIMI logos and certificates of domains with BIMI configured at
+			# bimi.<domain>. A DNS record for bimi.<domain> pointing to this listener is
+			# required. Requires a TLS config. (optional)
+			BIMIHTTPS:
+				Enabled: false
+
+				# TLS port, 443 by default. You should only override this if you cannot listen on
+				# port 443 directly. BIMI logos will be requested on port 443, so you'll have to
+				# add an external mechanism to get the connection here, e.g. by configuring port
+				# forwarding. (optional)
+				Port: 0
+
+				# If set, plain HTTP instead of HTTPS is spoken on the configured port. Can be
+				# useful when the bimi domain is reverse proxied. (optional)
+				NonTLS: false
+
 			# All configured WebHandlers will serve on an enabled listener. (optional)
 			WebserverHTTP:
 				Enabled: false
@@ -725,6 +929,27 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# remote SMTP servers. (optional)
 				DisableIPv6: false
 
+				# Address family to connect with first for the first delivery attempt to a remote
+				# SMTP server that has both IPv4 and IPv6 addresses: ipv6 or ipv4. If empty, the
+				# order of IPs from DNS is used. For later delivery attempts, the other address
+				# family is tried first, e.g. to work around a blocklisted IP. Use a route with
+				# ToDomain to a direct transport with another preference to override the
+				# preference for specific destination domains. (optional)
+				PreferIPFamily:
+
+				# Delay before starting a connection attempt to the next IP address of a remote
+				# SMTP server while a previous attempt is still in progress, alternating between
+				# IPv6 and IPv4 addresses. The first connection that succeeds is used, so a
+				# destination with broken IPv6 (or IPv4) connectivity doesn't cause a delay until
+				# a connection timeout. Known as "happy eyeballs", RFC 8305. Default 250ms.
+				# (optional)
+				HappyEyeballsDelay: 0s
+
+				# If set, IP addresses of a remote SMTP server are dialed one at a time, only
+				# trying the next IP after the previous connection attempt failed or timed out.
+				# (optional)
+				DisableHappyEyeballs: false
+
 	# Do not send DMARC reports (aggregate only). By default, aggregate reports on
 	# DMARC evaluations are sent to domains if their DMARC policy requests them.
 	# Reports are sent at whole hours, with a minimum of 1 hour and maximum of 24
@@ -747,6 +972,12 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 	# (optional)
 	OutgoingTLSReportsForAllSuccess: false
 
+	# Also send TLS reports to https URIs in the rua field of TLSRPT records, with an
+	# HTTP POST of the gzipped JSON report. By default, only mailto URIs are used:
+	# reports sent over HTTPS are not DKIM-signed so cannot be authenticated, and
+	# receivers may ignore them. (optional)
+	OutgoingTLSReportsHTTPS: false
+
 	# Default maximum total message size in bytes for each individual account, only
 	# applicable if greater than zero. Can be overridden per account. Attempting to
 	# add new messages to an account beyond its maximum total size will result in an
@@ -756,6 +987,509 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 	# (optional)
 	QuotaMessageSize: 0
 
+	# If non-zero, percentage of the maximum total message size of an account (see
+	# QuotaMessageSize) that is the soft limit. Accounts can temporarily go over the
+	# soft limit, during a grace period. Once the grace period has ended, new messages
+	# are rejected until the account is below the soft limit again. The maximum total
+	# message size is always enforced. (optional)
+	QuotaSoftPercent: 0
+
+	# Period during which an account can be over its soft limit, see QuotaSoftPercent.
+	# Default 7 days. (optional)
+	QuotaGracePeriod: 0s
+
+	# Percentages of the maximum total message size of an account at which a warning
+	# message is delivered to the Inbox of the account. When the lowest percentage is
+	# reached, IMAP logins also get an alert and the webmail shows a warning. Default
+	# 80, 90 and 95. (optional)
+	QuotaWarnPercentages:
+		- 0
+
+	# If non-zero, messages for which delivery from the queue failed permanently
+	# (including after exhausting all delivery attempts) are kept in a dead-letter
+	# queue for this period, with their message file. A DSN is still delivered to the
+	# sender. Messages in the dead-letter queue can be inspected, exported, have their
+	# recipient changed and be requeued for delivery by the admin. Useful for
+	# recovering from mistyped recipient domains and remote outages longer than the
+	# retry schedule. Reports (DMARC, TLS) are never kept. E.g. 720h (30 days).
+	# (optional)
+	QueueDeadLetterPeriod: 0s
+
+	# If set, reputation data about our outgoing email is periodically (daily) fetched
+	# from the configured mailbox providers, and made available in the admin web
+	# interface alongside the volume of our outgoing deliveries to those providers.
+	# (optional)
+	PostmasterTools:
+
+		# Key for automated access to Microsoft Smart Network Data Services (SNDS) data,
+		# for the IPs registered at SNDS. Can be found in the SNDS interface, at
+		# 'Automated Access'. (optional)
+		SNDSKey:
+
+		# OAuth2 client ID for the Google Postmaster Tools API, for domains verified at
+		# Google Postmaster Tools. All three Google fields must be set to fetch data from
+		# Google. (optional)
+		GoogleClientID:
+
+		# OAuth2 client secret for the client ID. (optional)
+		GoogleClientSecret:
+
+		# OAuth2 refresh token, with scope
+		# https://www.googleapis.com/auth/postmaster.readonly, for the Google account the
+		# domains are verified with. (optional)
+		GoogleRefreshToken:
+
+	# If set, incoming messages that are evaluated for their content, i.e. from
+	# senders without conclusive reputation, are also checked by an external spam
+	# filter, rspamd or SpamAssassin's spamd. The verdict is combined with the
+	# Bayesian junk filter of the account according to Policy. X-Spam-Status,
+	# X-Spam-Score, X-Spam-Flag and X-Spam-Symbols headers are added to delivered
+	# messages. If the external filter fails, e.g. because it is not running, messages
+	# are evaluated without it. (optional)
+	SpamFilter:
+
+		# URL of the HTTP API of rspamd (its normal worker), e.g. http://localhost:11333.
+		# Messages are submitted to /checkv2. Exactly one of Rspamd and Spamd must be set.
+		# (optional)
+		Rspamd:
+
+		# Password for the rspamd HTTP API, if it requires one. (optional)
+		RspamdPassword:
+
+		# Address of SpamAssassin's spamd, as host:port, e.g. localhost:783. (optional)
+		Spamd:
+
+		# Maximum duration of a check. Default 30s. (optional)
+		Timeout: 0s
+
+		# How the verdict of the external filter is combined with the verdict of the
+		# Bayesian junk filter of the account: either (message is junk if either filter
+		# considers it junk), both (message is junk only if both filters consider it junk)
+		# or external (only the external filter is used). If the account has no junk
+		# filter, only the external filter is used. Default: either. (optional)
+		Policy:
+
+		# If non-zero, messages with a score from the external filter at or above this
+		# value are rejected, regardless of the Bayesian junk filter. (optional)
+		RejectScore: 0.000000
+
+		# If non-zero, messages with a score from the external filter at or above this
+		# value, but below RejectScore, are accepted and delivered to the Junk mailbox of
+		# the account. If the account has no mailbox with the Junk special-use role, the
+		# message is rejected. (optional)
+		QuarantineScore: 0.000000
+
+	# If set, URLs and attachments of incoming messages that are evaluated for their
+	# content, i.e. from senders without conclusive reputation, are checked against
+	# local lists and DNS-based block lists. A listed URL domain or attachment hash
+	# rejects the message, or delivers it to the Junk mailbox if Quarantine is set.
+	# (optional)
+	ContentReputation:
+
+		# DNS zones of URI block lists, like SURBL and URIBL, to look up the domains of
+		# URLs in text and HTML parts in. Domains are looked up by their organizational
+		# domain, e.g. example.com for www.example.com. Example: multi.surbl.org. See the
+		# terms of use of the block list providers. (optional)
+		URIBLs:
+			-
+
+		# DNS zones of block lists with attachment hashes. Attachments are looked up by
+		# the lower-case hexadecimal SHA-256 hash of their decoded content, as a label in
+		# the zone. (optional)
+		HashBLs:
+			-
+
+		# Domains that are not allowed in URLs. Subdomains are also blocked. (optional)
+		BlockedDomains:
+			-
+
+		# File with blocked hexadecimal SHA-256 hashes of attachments, one per line. Empty
+		# lines and lines starting with # are ignored. Relative paths are relative to the
+		# directory of mox.conf. The file is read when the configuration is loaded.
+		# (optional)
+		HashFile:
+
+		# Maximum number of URL domains to check per message, to limit DNS lookups.
+		# Default 20. (optional)
+		MaxURLs: 0
+
+		# Duration to cache results of DNS block list lookups. Default 1h. (optional)
+		CacheTTL: 0s
+
+		# Deliver messages with listed URLs or attachments to the Junk mailbox of the
+		# account instead of rejecting them. If the account has no mailbox with the Junk
+		# special-use role, the message is rejected. (optional)
+		Quarantine: false
+
+	# If set, messages submitted by accounts, over SMTP, the webmail and the webapi,
+	# are checked for anomalies that indicate a compromised account: a sudden spike in
+	# the number of messages, and a high rate of recipients for which delivery failed,
+	# e.g. unknown recipients. Depending on Action, sending is throttled or suspended,
+	# and an alert is delivered to the postmaster. Optionally, submissions from
+	# networks or at hours not seen before for an account also cause an alert.
+	# Compromised accounts are a common cause for mail servers getting listed on block
+	# lists. (optional)
+	OutgoingAnomalies:
+
+		# What to do when a volume spike or high rate of failed recipients is detected, in
+		# addition to alerting the postmaster: alert (nothing), throttle (temporarily
+		# reject submissions while the anomaly persists) or suspend (reject submissions
+		# until an admin resumes sending for the account). Default: throttle. (optional)
+		Action:
+
+		# A volume spike is detected when the number of messages submitted in the past
+		# hour is this factor higher than the hourly average over the preceding 7 days.
+		# Default 10. (optional)
+		VolumeFactor: 0
+
+		# Minimum number of messages in the past hour before a volume spike can be
+		# detected. Default 50. (optional)
+		VolumeMinimum: 0
+
+		# A high rate of failed recipients is detected when delivery failed permanently
+		# for at least this percentage of the recipients in the past 24 hours, e.g.
+		# because the recipient does not exist. Default 30. (optional)
+		FailedPercentage: 0
+
+		# Minimum number of failed recipients in the past 24 hours before a high rate of
+		# failed recipients can be detected. Default 10. (optional)
+		FailedMinimum: 0
+
+		# If set, an alert is delivered when an account with history submits over SMTP
+		# from a network (IPv4 /24, IPv6 /48) not used in the past 30 days. Sending is not
+		# throttled or suspended. (optional)
+		UnusualNetworks: false
+
+		# If set, an alert is delivered when an account with history submits at an hour of
+		# the day at which it has not submitted in the past 30 days. Sending is not
+		# throttled or suspended. (optional)
+		UnusualHours: false
+
+	# If set, new message files of accounts are stored compressed, and message files
+	# stored before compression was enabled are compressed in the background after
+	# startup. Messages are decompressed transparently when read, e.g. for IMAP FETCH,
+	# exports and the webmail. Messages are compressed with DEFLATE, in independently
+	# compressed chunks so parts of messages can be read without decompressing the
+	# whole message. Quota and message sizes are about the uncompressed messages. The
+	# admin web interface shows the uncompressed and stored sizes of an account.
+	# (optional)
+	MessageCompression:
+
+		# Compression level, from 1 (fastest) to 9 (smallest). Default 6. (optional)
+		Level: 0
+
+		# Messages smaller than this size in bytes are not compressed. Default 1024.
+		# (optional)
+		MinSize: 0
+
+	# If set, the full-text search index of accounts stores salted hashes of words
+	# instead of the words, so the index does not hold message text as is. The webmail
+	# hashes search words before sending them, so they aren't sent to the server
+	# either. This is not protection against anyone who can read the account database:
+	# the salt is stored in the same database and sent to webmail clients, so hashes
+	# of likely words, e.g. from a dictionary, can be computed and compared against
+	# the index. Trade-offs: Only whole words can be searched for, no substrings or
+	# phrases (each word of a phrase is matched separately), words are matched as the
+	# index was built without verifying against the message, search is only possible
+	# when the index of the account is complete, and IMAP searches don't use the
+	# index. Changing this setting rebuilds the indexes of accounts, in the
+	# background, when they are opened. (optional)
+	SearchIndexHashed: false
+
+	# If set, message files with identical contents, e.g. for a message delivered to
+	# multiple local recipients, to mailing list subscribers, or imported multiple
+	# times, are stored once, keyed by a hash of their contents, and hard linked into
+	# the accounts. The number of hard links to a stored file is its reference count:
+	# files in the deduplication store that are no longer referenced by any account
+	# are removed periodically. Message prefixes with per-recipient headers are stored
+	# in the database, so message files are often identical across recipients. Quota
+	# and message sizes are not affected. Not available on Windows. (optional)
+	MessageDeduplication:
+
+		# Message files smaller than this size in bytes are not deduplicated. Default
+		# 4096. (optional)
+		MinSize: 0
+
+	# If set, authentication can be delegated to an OpenID Connect identity provider,
+	# e.g. for single sign-on within an organization. The account and webmail web
+	# interfaces offer logging in through the provider, and IMAP and SMTP submission
+	# accept OAuth 2.0 access tokens issued by the provider with SASL mechanisms
+	# OAUTHBEARER and XOAUTH2, so mail clients don't have to store passwords. Access
+	# tokens are verified with the token introspection endpoint of the provider, and
+	# must have the client ID of mox as audience, or be issued to the client ID of
+	# mox. Users are matched to accounts by email address. Second factors are left to
+	# the identity provider, password policies and two-factor authentication
+	# configured in mox don't apply to these logins. (optional)
+	OIDC:
+
+		# Issuer URL of the identity provider, e.g.
+		# https://sso.example.org/realms/example. Its configuration is fetched from
+		# <issuer>/.well-known/openid-configuration. Must be an https URL.
+		Issuer:
+
+		# Client ID of mox as registered at the identity provider. For web logins, the
+		# provider must allow redirect URIs ending in oidc/callback below the paths of the
+		# account and webmail web interfaces, e.g.
+		# https://mail.example.org/webmail/oidc/callback.
+		ClientID:
+
+		# Client secret for the client ID, if the provider requires one. (optional)
+		ClientSecret:
+
+		# Additional scopes to request during web logins. Scopes openid and email are
+		# always requested. (optional)
+		Scopes:
+			-
+
+		# Claim with the email address of the user, matched against the addresses of the
+		# accounts. The identity provider must set claim email_verified to true, also when
+		# another claim is used for the address. Default: email. (optional)
+		AddressClaim:
+
+	# If set, DMARC failure reports are sent about incoming messages that fail DMARC,
+	# to domains that request them with "ruf" in their DMARC record. Failure reports
+	# are about a single message and can reveal details about our users, so they are
+	# not sent by default. Reports only contain the header of a message, never the
+	# body. Reports are not sent about messages rejected for being junk. Reports are
+	# sent from the postmaster@<mailhostname> address. (optional)
+	OutgoingDMARCFailureReports:
+
+		# Replace the localparts of email addresses in the reported message header and
+		# SMTP envelope with a hash, as described in RFC 6590, so reports don't reveal
+		# which of our users received the message. The same localpart is replaced with the
+		# same hash while mox is running, so reports can still be correlated. (optional)
+		Redact: false
+
+		# Maximum number of failure reports to send per policy domain per day. Default 10.
+		# (optional)
+		MaxPerDay: 0
+
+	# Exemptions from checks for trusted networks, e.g. internal relays and monitoring
+	# systems, and for authenticated submissions by accounts, e.g. of applications
+	# sending bulk messages. All exemptions are configured in this list, so they can
+	# be reviewed in one place. Applied exemptions are logged, including their
+	# comment. (optional)
+	Exemptions:
+		-
+
+			# Free-form comment, e.g. why the exemption exists. Included in log lines when the
+			# exemption is applied. (optional)
+			Comment:
+
+			# IP networks in CIDR notation, e.g. 192.0.2.0/24 or 2001:db8::/32, or single IP
+			# addresses. The exemption applies to SMTP connections, and incoming deliveries,
+			# from these networks. (optional)
+			Networks:
+				-
+
+			# Accounts whose authenticated submissions, over SMTP, the webmail and the webapi,
+			# the exemption applies to, or "*" for all accounts. Only RateLimits applies to
+			# submissions, they are never junk filtered, checked against DNSBLs or delayed.
+			# (optional)
+			Accounts:
+				-
+
+			# Incoming deliveries are not evaluated for junk: sender reputation, the junk
+			# filter, the external spam filter, content reputation and DNSBLs are skipped, and
+			# messages are delivered to their destination mailbox. DMARC reject policies and
+			# the sender lists of the account still apply. (optional)
+			JunkFilter: false
+
+			# The remote IP of incoming deliveries is not checked against the DNSBLs of the
+			# listener. (optional)
+			DNSBL: false
+
+			# Incoming deliveries are not delayed: the first-time sender delay of the listener
+			# and the delay before rejecting deliveries to unknown recipients are skipped.
+			# (optional)
+			Delays: false
+
+			# Rate limits are not applied. For networks: the limits on connection rate, open
+			# connections, and messages and bytes delivered per IP and network. For accounts:
+			# MaxOutgoingMessagesPerDay, MaxFirstTimeRecipientsPerDay and OutgoingAnomalies.
+			# (optional)
+			RateLimits: false
+
+	# Domains of intermediaries, e.g. mailing lists or university forwarders, whose
+	# ARC (Authenticated Received Chain) results are trusted for incoming messages
+	# that fail DMARC, typically because the intermediary modified the message. If
+	# such a message has a valid ARC chain, the latest ARC set was sealed by one of
+	# these domains, and the authentication results recorded by that sealer have a
+	# DMARC pass for the domain of the message From address, the DMARC policy is not
+	# applied, and the From address is considered validated for reputation-based junk
+	# filtering. The override is included in outgoing DMARC aggregate reports as
+	# trusted_forwarder. (optional)
+	TrustedARCSealers:
+		-
+
+	# If set, passwords are also verified against an LDAP directory, for logins with
+	# addresses not configured in mox, and for accounts whose password doesn't match.
+	# Users are searched for in the directory, and their credentials verified by
+	# binding as the user. The user entry is mapped to a mox account through an
+	# attribute, and accounts can be created automatically on first login. Two-factor
+	# authentication and app password requirements configured in mox still apply.
+	# Accounts can still have a local password. Directory passwords can only be used
+	# with authentication mechanisms that send the password, like IMAP LOGIN and SASL
+	# PLAIN, not with SCRAM-SHA-* and CRAM-MD5. (optional)
+	LDAP:
+
+		# URL of the LDAP server, ldaps://host[:port] for TLS (default port 636), or
+		# ldap://host[:port] (default port 389).
+		URL:
+
+		# For ldap:// URLs, upgrade the connection to TLS with the StartTLS operation
+		# before sending credentials. Without StartTLS, passwords are sent in plain text,
+		# only use that for connections to localhost. (optional)
+		StartTLS: false
+
+		# DN to bind as for searching the user entry, e.g. of a service account. If empty,
+		# the search is done anonymously. (optional)
+		BindDN:
+
+		# Password for BindDN. (optional)
+		BindPassword:
+
+		# DN under which to search for the user entry, e.g. ou=people,dc=example,dc=org.
+		BaseDN:
+
+		# Search filter, in RFC 4515 syntax, for finding the user entry with %s replaced
+		# by the escaped login name. Must match a single entry. Default: (mail=%s).
+		# (optional)
+		Filter:
+
+		# Attribute of the user entry with the name of the mox account to login to.
+		# Default: uid. (optional)
+		AccountAttribute:
+
+		# Attribute of the user entry with the email address for an account created with
+		# AutoProvision. Default: mail. (optional)
+		AddressAttribute:
+
+		# Create the account on first successful login if it doesn't exist yet, with the
+		# email address from AddressAttribute. The domain of the address must be
+		# configured in mox. (optional)
+		AutoProvision: false
+
+	# Configuration for the audit log, with security-relevant events like logins,
+	# password and settings changes, configuration changes by admins, account
+	# additions and removals, exports and queue changes. The audit log is enabled by
+	# default and kept in the auth database. It can be viewed in the admin web
+	# interface, and exported with "mox auditlog export", as JSON lines or syslog
+	# messages. (optional)
+	AuditLog:
+
+		# Do not record events in the audit log. (optional)
+		Disabled: false
+
+		# Period after which events are removed from the audit log. Default: 8760h (365
+		# days). (optional)
+		Retention: 0s
+
+	# If set, the provisioning API is enabled, a REST/JSON API for creating,
+	# suspending, changing the quota of and removing accounts and their addresses, for
+	# hosting panels and automation. It is served at provisioning/v0/ below the path
+	# of the admin web interface. Requests are authenticated with bearer tokens,
+	# managed with "mox provisioning token". Mutating requests can have an
+	# Idempotency-Key header, a repeated request with the same key gets the original
+	# response without making changes again. (optional)
+	ProvisioningAPI:
+
+		# If set, a JSON notification is sent with an HTTP POST to this URL after each
+		# change made through the provisioning API. Failed notifications are retried a few
+		# times. (optional)
+		WebhookURL:
+
+		# Value for the Authorization header in notification requests. (optional)
+		WebhookAuthorization:
+
+		# If set, notifications have a header X-Mox-Webhook-Signature with the hex-encoded
+		# HMAC-SHA256 of the request body with this key, prefixed with "sha256=".
+		# (optional)
+		WebhookSigningKey:
+
+	# Abuse protections for the self-service signup of accounts, for domains with
+	# Signup configured in domains.conf. (optional)
+	Signup:
+
+		# Maximum number of signups per day from a remote IP address. Limits also apply to
+		# the /24 (IPv4) or /48 (IPv6) network, and to the /16 or /32 network, at 3 and 9
+		# times this number. Default 5. (optional)
+		MaxPerIPPerDay: 0
+
+		# If set, signups are verified with a captcha service. The response from the
+		# captcha widget is posted to this URL with the secret and the remote IP, as form
+		# fields "secret", "response" and "remoteip", and the JSON response must have
+		# field "success" set to true. Compatible with hCaptcha, reCAPTCHA and Cloudflare
+		# Turnstile, e.g. https://hcaptcha.com/siteverify. (optional)
+		CaptchaVerifyURL:
+
+		# Secret for the captcha service. (optional)
+		CaptchaSecret:
+
+		# HTML for the captcha widget, included in the signup form, e.g. a script and div
+		# element as documented by the captcha service. The widget must add a form field
+		# h-captcha-response, g-recaptcha-response, cf-turnstile-response or
+		# captcha-response. (optional)
+		CaptchaHTML:
+
+	# DNS providers through which mox can create and update its own DNS records (DKIM,
+	# SPF, MTA-STS, TLSA) for domains, and rotate DKIM keys. Domains reference a
+	# provider by name in their DNSProvisioning config. Exactly one of the provider
+	# types must be set per provider. (optional)
+	DNSProviders:
+		x:
+
+			# DNS UPDATE messages (RFC 2136) sent to a primary name server, authenticated with
+			# TSIG. (optional)
+			RFC2136:
+
+				# Host and port of the primary name server for the zones, e.g. ns1.example.org:53.
+				# Messages are sent over TCP.
+				Server:
+
+				# Name of the TSIG key, as configured in the name server.
+				TSIGKeyName:
+
+				# Base64-encoded TSIG secret.
+				TSIGSecret:
+
+				# TSIG algorithm, hmac-sha256 (default) or hmac-sha512. (optional)
+				TSIGAlgorithm:
+
+			# Cloudflare API, with an API token with permission to edit DNS records of the
+			# zones. (optional)
+			Cloudflare:
+
+				# API token.
+				APIToken:
+
+				# Base URL of the API, to override the default, e.g. for testing. (optional)
+				BaseURL:
+
+			# deSEC.io API, with an API token. (optional)
+			DeSEC:
+
+				# API token.
+				APIToken:
+
+				# Base URL of the API, to override the default, e.g. for testing. (optional)
+				BaseURL:
+
+			# Amazon Route 53 API. (optional)
+			Route53:
+
+				# AWS access key ID.
+				AccessKeyID:
+
+				# AWS secret access key.
+				SecretAccessKey:
+
+				# ID of the hosted zone, e.g. Z0123456789ABCDEFGHIJ.
+				HostedZoneID:
+
+				# Base URL of the API, to override the default, e.g. for testing. (optional)
+				BaseURL:
+
 # domains.conf
 
 	# NOTE: This config file is in 'sconf' format. Indent with tabs. Comments must be
@@ -852,6 +1586,60 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				Sign:
 					-
 
+				# Rules for selecting the selectors to sign an outgoing message with, instead of
+				# the selectors in Sign. Rules are evaluated in order, the first matching rule
+				# determines the selectors. If no rule matches, the selectors in Sign are used. A
+				# message can be signed with multiple selectors, e.g. one with an RSA and one with
+				# an ed25519 key, each with their own canonicalization and headers. (optional)
+				SignRules:
+					-
+
+						# Matches if this regular expression matches (a substring of) the address in the
+						# message From header. E.g. '^newsletter@example\.org$'. (optional)
+						MsgFromRegexp:
+
+						# Matches if these header field/value regular expressions all match (substrings
+						# of) the message headers. Header fields and values are converted to lower case
+						# before matching. Whitespace is trimmed from the value before matching. A header
+						# field can occur multiple times in a message, only one instance has to match.
+						# (optional)
+						HeadersRegexp:
+							x:
+
+						# Matches if the message is submitted by this account, through SMTP submission,
+						# webmail or the webapi. Messages generated by mox itself, such as DSNs and
+						# reports, never match. (optional)
+						Account:
+
+						# Selectors to sign the message with if this rule matches.
+						Sign:
+							-
+
+						# Free-form comments. (optional)
+						Comment:
+
+				# If set, DKIM keys for signing are periodically replaced with new keys, also for
+				# domains without DNS provisioning. The DNS records for new selectors must then be
+				# added manually, they are listed as tasks in the admin web interface. Signing
+				# switches to the new keys only after their DNS records are found in DNS. Old
+				# selectors are removed from the configuration after the retain period, their DNS
+				# records should then be removed manually. For domains with DNS provisioning, the
+				# DKIMRotation setting in DNSProvisioning can be used instead, not both.
+				# (optional)
+				Rotation:
+
+					# Period between rotations, starting at the previous rotation. Default 2160h (90
+					# days). (optional)
+					Interval: 0s
+
+					# Period new selectors are published in DNS before signing with them, so they have
+					# propagated. Default 48h. (optional)
+					PublishDelay: 0s
+
+					# Period old selectors remain published in DNS after signing has switched to new
+					# selectors, for verifying messages in transit. Default 168h (7 days). (optional)
+					RetainPeriod: 0s
+
 			# With DMARC, a domain publishes, in DNS, a policy on how other mail servers
 			# should handle incoming messages with the From-header matching this domain and/or
 			# subdomain (depending on the configured alignment). Receiving mail servers use
@@ -884,6 +1672,13 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# Mailbox to deliver to, e.g. DMARC.
 				Mailbox:
 
+				# If non-zero, a message is delivered to the mailbox above when received aggregate
+				# reports show a spike in messages failing DMARC: at least this percentage of
+				# messages in reports of the past two days failed, and that rate is at least twice
+				# the rate of the preceding week. At most one alert is sent per day. Reports must
+				# cover at least 10 messages. Value between 1 and 100. (optional)
+				FailureAlertPercentage: 0
+
 			# MTA-STS is a mechanism that allows publishing a policy with requirements for
 			# WebPKI-verified SMTP STARTTLS connections for email delivered to a domain.
 			# Existence of a policy is announced in a DNS TXT record (often
@@ -1001,6 +1796,350 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 					# message From header. (optional)
 					AllowMsgFrom: false
 
+					# Who can send messages to the list, overriding PostPublic if set. Values: members
+					# (only members, based on message From address), anyone, moderated (only owners;
+					# messages from others, including members, are only delivered to the owners for
+					# review, who can resend approved messages to the list). (optional)
+					PostPolicy:
+
+					# Addresses of local accounts that manage the list. Owners can add and remove
+					# members in the account web interface, and are the moderators of a moderated
+					# list. Owners don't have to be members. (optional)
+					Owners:
+						-
+
+					# If true, List-Id, List-Post and List-Unsubscribe headers are added to messages
+					# delivered through the alias, so mail clients can recognize the list and show an
+					# unsubscribe option. The List-Unsubscribe header links to the account web
+					# interface, where members can leave the list. (optional)
+					ListHeaders: false
+
+					# Delivery preferences of members, keyed by member address. Members change their
+					# own preferences in the account web interface. (optional)
+					Members:
+						x:
+
+							# If true, messages to the list are not delivered to the member. The member can
+							# still send to the list. (optional)
+							NoDelivery: false
+
+							# Mailbox to deliver messages from the list to, instead of the mailbox of the
+							# destination of the member address. (optional)
+							Mailbox:
+
+					# If set, the alias is also a mailing list with subscribers, which can be external
+					# addresses. Messages to the list are sent to confirmed subscribers through the
+					# queue, with list headers, DKIM-signed and ARC-sealed with the keys of the
+					# domain, and with the From address rewritten to the list address if the DMARC
+					# policy of the author domain would cause the message to be rejected. Subscribers
+					# can choose to receive a daily digest instead. Email commands are sent to the
+					# list address with the command after a localpart catchall separator, e.g.
+					# list+subscribe@example.com and list+unsubscribe@example.com, so the domain must
+					# have a LocalpartCatchallSeparator. Subscribers can also manage their
+					# subscription on the web page at list/ below the account web interface.
+					# Subscribers can send to the list like members. (optional)
+					List:
+
+						# If true, anyone can subscribe, by sending a message with command subscribe or
+						# through the web page. Subscriptions must be confirmed through a message sent to
+						# the subscribing address (double opt-in). Otherwise, only admins add subscribers.
+						# Unsubscribing is always possible. (optional)
+						Subscribe: false
+
+						# If true, messages sent to the list are kept in an archive, viewable by admins.
+						# (optional)
+						Archive: false
+
+						# Number of delivery failures after which a subscriber is automatically
+						# unsubscribed. Failures are forgotten after 30 days without failures. Default 5.
+						# (optional)
+						BounceLimit: 0
+
+			# Changes to the message header of outgoing messages with a message From address
+			# of this domain, made during submission before DKIM signing. Rules of the account
+			# are applied after those of the domain. (optional)
+			OutgoingHeaderRules:
+
+				# Keys of header fields to remove, case-insensitive. A trailing "*" matches any
+				# suffix, e.g. "X-Internal-*". (optional)
+				Remove:
+					-
+
+				# If set, the display name in the From header is replaced with this value. The
+				# text "{name}" is replaced with the original display name, e.g. "{name} (Example
+				# Corp)". (optional)
+				FromDisplayName:
+
+				# If set, the Reply-To header is set to this address, replacing any Reply-To
+				# header in the message. E.g. "Support <support@example.com>". (optional)
+				ReplyTo:
+
+				# Header fields to add, in the form "Key: value", e.g. an organization disclaimer.
+				# Existing header fields with the same key are removed. Non-ASCII text is encoded.
+				# (optional)
+				Add:
+					-
+
+			# Footer, e.g. a disclaimer, added to the text of outgoing messages with a message
+			# From address of this domain, during submission. Not used if the account has its
+			# own footer. (optional)
+			OutgoingFooter:
+
+				# Lines of text for the footer, added to text/plain parts. Can contain non-ASCII
+				# characters.
+				Text:
+					-
+
+				# HTML for the footer, added to text/html parts before the closing body tag. If
+				# empty, the HTML footer is generated from the text lines. (optional)
+				HTML:
+
+			# Templates for composing messages in the webmail, e.g. canned responses, shared
+			# with all accounts that have an address in this domain. Accounts can also have
+			# their own templates, managed in the webmail. (optional)
+			MessageTemplates:
+				-
+
+					# Name of the template, unique within the domain.
+					Name:
+
+					# Subject for the message. If empty, the subject in the compose window is left as
+					# is. (optional)
+					Subject:
+
+					# Lines of text for the message body. Can contain non-ASCII characters. (optional)
+					Text:
+						-
+
+			# If set, accounts with this domain as their default domain must use two-factor
+			# authentication, as if RequireTwoFactor is set for the account. (optional)
+			RequireTwoFactor: false
+
+			# Default maximum total message size in bytes for accounts with this domain as
+			# their default domain, overriding the globally configured default if non-zero.
+			# Can be overridden per account. A negative value can be used to have no limit in
+			# case there is a limit by default. (optional)
+			QuotaMessageSize: 0
+
+			# If set, mox creates and updates DNS records for this domain through a DNS
+			# provider: DKIM records for its selectors, the SPF record, the MTA-STS record,
+			# the BIMI record and TLSA records for the mail host if it is in the zone. DKIM
+			# keys can be rotated automatically. (optional)
+			DNSProvisioning:
+
+				# Name of DNS provider in mox.conf.
+				Provider:
+
+				# DNS zone the records for the domain are in, if not the domain itself, e.g. for a
+				# subdomain. (optional)
+				Zone:
+
+				# Time to live in seconds for records. Default 300. (optional)
+				TTL: 0
+
+				# If set, DKIM keys for signing are periodically replaced with new keys. New
+				# selectors are first published in DNS while still signing with the old keys.
+				# After a delay, messages are signed with the new keys. Old keys stay published
+				# for a while, so messages in transit can still be verified, before they are
+				# removed. (optional)
+				DKIMRotation:
+
+					# Period between rotations, starting at the previous rotation. Default 2160h (90
+					# days). (optional)
+					Interval: 0s
+
+					# Period new selectors are published in DNS before signing with them, so they have
+					# propagated. Default 48h. (optional)
+					PublishDelay: 0s
+
+					# Period old selectors remain published in DNS after signing has switched to new
+					# selectors, for verifying messages in transit. Default 168h (7 days). (optional)
+					RetainPeriod: 0s
+
+			# If set, accounts with an address at this domain can be requested through the
+			# self-service signup page, served at signup/ below the path of the account web
+			# interface. Signups require an invitation created by an admin, unless open. The
+			# contact email address given in the signup is verified before the account is
+			# created. Role addresses like postmaster and abuse, and the DMARC and TLS
+			# reporting addresses, cannot be requested. Abuse protections are configured with
+			# Signup in mox.conf. (optional)
+			Signup:
+
+				# Allow signups without invitation. By default, an invitation created by an admin
+				# is required. (optional)
+				Open: false
+
+				# After verification of the contact email address, an admin must approve the
+				# signup before the account is created. (optional)
+				RequireApproval: false
+
+				# Maximum total message size in bytes for accounts created through signup. If
+				# zero, the default for the domain applies. (optional)
+				QuotaMessageSize: 0
+
+			# Customization of the webmail and account web interfaces, e.g. for hosting
+			# providers to white-label the interfaces for customer domains. Branding applies
+			# to requests with a Host header for this domain or a subdomain, e.g.
+			# mail.<domain>, unless the subdomain is a configured domain itself. Default
+			# webmail settings and disabled features apply to accounts with this domain as
+			# their default domain. (optional)
+			Web:
+
+				# Name of the service, shown in page titles and on the login page instead of "Mox
+				# Webmail" and "Mox Account". (optional)
+				Name:
+
+				# Image file shown on the login page, e.g. PNG, JPEG or SVG. Relative paths are
+				# relative to the config directory. Served as branding/logo below the path of the
+				# web interfaces. (optional)
+				LogoFile:
+
+				# Text shown on the login page, e.g. with contact information for support.
+				# (optional)
+				LoginText:
+
+				# CSS color for links and buttons, e.g. #1a73e8. (optional)
+				Color:
+
+				# CSS color for the page background. (optional)
+				BackgroundColor:
+
+				# File with additional CSS, like webmail.css and webaccount.css in the config
+				# directory but only for this domain, and included for both interfaces. Relative
+				# paths are relative to the config directory. (optional)
+				CSSFile:
+
+				# Initial webmail settings for new accounts. Existing accounts keep their
+				# settings. (optional)
+				WebmailDefaults:
+
+					# Show the HTML version of messages by default, instead of the plain text version.
+					# (optional)
+					ShowHTML: false
+
+					# Show bars underneath address input fields indicating security support of the
+					# recipient domain. (optional)
+					ShowAddressSecurity: false
+
+					# Don't show keyboard shortcuts after mouse interaction. (optional)
+					NoShowShortcuts: false
+
+					# Load remote content in HTML messages through a proxy in mox. (optional)
+					RemoteContentProxy: false
+
+					# Number of seconds, between 5 and 30, that sent messages are held before
+					# delivery, during which sending can be undone. Zero disables. (optional)
+					UndoSendSeconds: 0
+
+				# Features of the web interfaces that cannot be used by accounts, and that are not
+				# shown. Valid values: import, export, webhooks, pgp, tlspubkeys. (optional)
+				DisabledFeatures:
+					-
+
+			# BIMI (Brand Indicators for Message Identification) lets mail clients of
+			# recipients show the logo of the domain for messages that pass DMARC, if the
+			# DMARC policy of the domain is quarantine (for all messages) or reject. The logo
+			# location is published in a DNS TXT record, the logo can be served by mox.
+			# (optional)
+			BIMI:
+
+				# Selector of the BIMI DNS record, published at <selector>._bimi.<domain>. Default
+				# is "default", which receiving mail servers use for messages without
+				# BIMI-Selector header. (optional)
+				Selector:
+
+				# File with the logo, in the SVG Tiny Portable/Secure format required by BIMI, at
+				# most 32KB. Relative paths are relative to the config directory. Served at
+				# https://bimi.<domain>/.well-known/bimi/<selector>.svg by listeners with
+				# BIMIHTTPS enabled. Either LogoFile or LogoURL must be set. (optional)
+				LogoFile:
+
+				# HTTPS URL of the logo, when hosted elsewhere instead of by mox. (optional)
+				LogoURL:
+
+				# File with the Verified Mark Certificate (VMC) for the logo in PEM format, served
+				# at https://bimi.<domain>/.well-known/bimi/<selector>.pem by listeners with
+				# BIMIHTTPS enabled. Some receiving mail servers only show logos with a VMC.
+				# Relative paths are relative to the config directory. (optional)
+				AuthorityFile:
+
+				# HTTPS URL of the VMC, when hosted elsewhere instead of by mox. (optional)
+				AuthorityURL:
+
+			# Default actions for incoming messages based on the spaminess score of the junk
+			# filter, for accounts with this domain as their default domain. Fields set in the
+			# JunkFilter of an account take precedence. (optional)
+			SpamActions:
+
+				# Spaminess score between 0 and 1 above which accepted messages get
+				# X-Mox-Spam-Flag and X-Mox-Spam-Score headers, so mail clients can filter on
+				# them. E.g. 0.5. Zero for no tagging. (optional)
+				TagThreshold: 0.000000
+
+				# Spaminess score between 0 and 1 above which messages are accepted but delivered
+				# to the Junk mailbox of the account. Should be below Threshold. If the account
+				# has no mailbox with the Junk special-use role, messages are delivered normally.
+				# E.g. 0.8. Zero to not deliver to Junk based on score. (optional)
+				JunkThreshold: 0.000000
+
+				# Action for messages with a spaminess score above Threshold: reject (the message
+				# is rejected during the SMTP transaction, with a copy stored in the rejects
+				# mailbox if configured) or quarantine (the message is accepted, so the sender
+				# does not learn it was considered spam, but only stored in the rejects mailbox,
+				# which must be configured). Default: reject. (optional)
+				HighAction:
+
+			# If set, a junk filter shared by all accounts with this domain as their default
+			# domain is trained along with the per-account junk filters, and its spaminess
+			# score is combined with that of the per-account junk filter during incoming
+			# deliveries. Useful for new accounts that have not trained their own junk filter
+			# yet. Only used for accounts with a JunkFilter configured. (optional)
+			SharedJunkFilter:
+
+				# Weight between 0 and 1 of the spaminess score of the shared junk filter when
+				# combined with the score of the per-account junk filter. If only one of the two
+				# filters has enough words for a significant score, that score is used. E.g. 0.3.
+				Weight: 0.000000
+				Params:
+
+					# Track ham/spam ranking for single words. (optional)
+					Onegrams: false
+
+					# Track ham/spam ranking for each two consecutive words. (optional)
+					Twograms: false
+
+					# Track ham/spam ranking for each three consecutive words. (optional)
+					Threegrams: false
+
+					# Maximum power a word (combination) can have. If spaminess is 0.99, and max power
+					# is 0.1, spaminess of the word will be set to 0.9. Similar for ham words.
+					MaxPower: 0.000000
+
+					# Number of most spammy/hammy words to use for calculating probability. E.g. 10.
+					TopWords: 0
+
+					# Ignore words that are this much away from 0.5 haminess/spaminess. E.g. 0.1,
+					# causing word (combinations) of 0.4 to 0.6 to be ignored. (optional)
+					IgnoreWords: 0.000000
+
+					# Occurrences in word database until a word is considered rare and its influence
+					# in calculating probability reduced. E.g. 1 or 2. (optional)
+					RareWords: 0
+
+			# Localparts of spamtrap addresses in this domain. Spamtrap addresses are never
+			# given out, so only spammers, e.g. with harvested or guessed addresses, send to
+			# them. Messages to spamtraps are accepted but never delivered. Instead, the
+			# server-wide reputation of the sending IP and the validated message From domain
+			# are updated, and the message is trained as spam in the shared junk filter of the
+			# domain, if configured. To protect against trap poisoning, messages with a null
+			# reverse path (e.g. bounces) are ignored, messages are not trained for senders
+			# with an allow override or with messages marked as not junk by users, and only
+			# the first messages of a sending IP are trained. Spamtraps must not also be
+			# configured as address of an account or alias. Localparts are encoded, as they
+			# appear in email addresses. (optional)
+			Spamtraps:
+				-
+
 	# Accounts represent mox users, each with a password and email address(es) to
 	# which email can be delivered (possibly at different domains). Each account has
 	# its own on-disk directory holding its messages and index database. An account
@@ -1023,6 +2162,16 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				Events:
 					-
 
+				# If not empty, HTTP requests are signed with HMAC-SHA256 over the request body
+				# with this key. The signature is added as hex-encoded value to header
+				# X-Mox-Webhook-Signature, prefixed with "sha256=". (optional)
+				SigningKey:
+
+				# If set, the SMTP protocol transcript of the last delivery attempt is included in
+				# webhooks about deliveries, without authentication and message data. Transcripts
+				# can be large. (optional)
+				Transcript: false
+
 			# Webhooks for events about incoming deliveries over SMTP. (optional)
 			IncomingWebhook:
 
@@ -1032,6 +2181,11 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# If not empty, value of Authorization header to add to HTTP requests. (optional)
 				Authorization:
 
+				# If not empty, HTTP requests are signed with HMAC-SHA256 over the request body
+				# with this key. The signature is added as hex-encoded value to header
+				# X-Mox-Webhook-Signature, prefixed with "sha256=". (optional)
+				SigningKey:
+
 			# Login addresses that cause outgoing email to be sent with SMTP MAIL FROM
 			# addresses with a unique id after the localpart catchall separator (which must be
 			# enabled when addresses are specified here). Any delivery status notifications
@@ -1192,10 +2346,11 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				Period: 0s
 
 			# Default maximum total message size in bytes for the account, overriding any
-			# globally configured default maximum size if non-zero. A negative value can be
-			# used to have no limit in case there is a limit by default. Attempting to add new
-			# messages to an account beyond its maximum total size will result in an error.
-			# Useful to prevent a single account from filling storage. (optional)
+			# default maximum size configured for the domain of the account or globally if
+			# non-zero. A negative value can be used to have no limit in case there is a limit
+			# by default. Attempting to add new messages to an account beyond its maximum
+			# total size will result in an error. Useful to prevent a single account from
+			# filling storage. (optional)
 			QuotaMessageSize: 0
 
 			# Mail that looks like spam will be rejected, but a copy can be stored temporarily
@@ -1246,6 +2401,29 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 				# spammers to identify words that strongly indicate non-spaminess and use it to
 				# bypass the filter. E.g. 0.95.
 				Threshold: 0.000000
+
+				# Tiered actions based on the spaminess score, e.g. tagging or delivering to the
+				# Junk mailbox below Threshold, and quarantining above it. Unset fields are taken
+				# from SpamActions of the default domain of the account. (optional)
+				SpamActions:
+
+					# Spaminess score between 0 and 1 above which accepted messages get
+					# X-Mox-Spam-Flag and X-Mox-Spam-Score headers, so mail clients can filter on
+					# them. E.g. 0.5. Zero for no tagging. (optional)
+					TagThreshold: 0.000000
+
+					# Spaminess score between 0 and 1 above which messages are accepted but delivered
+					# to the Junk mailbox of the account. Should be below Threshold. If the account
+					# has no mailbox with the Junk special-use role, messages are delivered normally.
+					# E.g. 0.8. Zero to not deliver to Junk based on score. (optional)
+					JunkThreshold: 0.000000
+
+					# Action for messages with a spaminess score above Threshold: reject (the message
+					# is rejected during the SMTP transaction, with a copy stored in the rejects
+					# mailbox if configured) or quarantine (the message is accepted, so the sender
+					# does not learn it was considered spam, but only stored in the rejects mailbox,
+					# which must be configured). Default: reject. (optional)
+					HighAction:
 				Params:
 
 					# Track ham/spam ranking for single words. (optional)
@@ -1272,6 +2450,126 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 					# in calculating probability reduced. E.g. 1 or 2. (optional)
 					RareWords: 0
 
+			# Re-evaluate recently received messages when new information about them becomes
+			# available after delivery, moving them from the Inbox to the Junk mailbox, or
+			# back. Only messages that are still in the mailbox they were delivered to are
+			# moved. The Junk mailbox is the mailbox with the Junk special-use role.
+			# (optional)
+			JunkReevaluation:
+
+				# Only messages received within this period are re-evaluated. Default 72h.
+				# (optional)
+				Window: 0s
+
+				# When a message is marked as junk through webmail or the webapi, by moving it to
+				# the Junk mailbox or by setting the $Junk flag, also move other messages in the
+				# Inbox from the same verified message From address to the Junk mailbox. Messages
+				# that were moved to the Inbox by the user, or that are marked as not junk, are
+				# not moved. Marking a message as not junk moves messages from the same verified
+				# sender from the Junk mailbox to the Inbox. (optional)
+				Sender: false
+
+				# Periodically check the remote IPs that messages in the Inbox were delivered from
+				# against the DNSBLs configured in the SMTP listeners. Messages from IPs that are
+				# now listed are moved to the Junk mailbox, unless the junk filter, if configured,
+				# classifies them as ham, with a spaminess below 0.5. (optional)
+				DNSBL: false
+
+				# When a message is sent to a spamtrap address, see Spamtraps in the domain
+				# configuration, move messages in the Inbox from the same remote IP, or from the
+				# same verified message From organizational domain, to the Junk mailbox. Like
+				# training from spamtraps, nothing is moved for senders with an allow override or
+				# with messages marked as not junk by users, and only for the first messages of a
+				# sending IP to spamtraps. Messages that were moved to the Inbox by the user, or
+				# that are marked as junk or not junk, are not moved. (optional)
+				Spamtrap: false
+
+				# Deliver a message to the Inbox listing the messages that were moved. (optional)
+				Notify: false
+
+			# If set, messages from senders that have not been seen before are delivered to a
+			# screening mailbox instead of the Inbox, until the sender is approved by moving
+			# one of their messages out of the screening mailbox (to a mailbox other than Junk
+			# or Trash), which adds the sender to the allow list. Senders on the block list
+			# are rejected. Senders are known if they are on the allow list and the message
+			# From address is verified, or if this account has sent them a message, or has
+			# received a message with a verified From address from them outside the screening
+			# mailbox and not marked as junk. Forwarded and mailing list messages, DSNs and
+			# messages delivered through rulesets are not screened. The allow and block lists
+			# are managed in the account web interface. (optional)
+			Screening:
+
+				# Mailbox for messages from first-time senders awaiting approval, created when
+				# needed. Default: Screening. (optional)
+				Mailbox:
+
+			# If set, incoming bulk messages that are not spam ('graymail'), such as
+			# newsletters, automated notifications and updates from social networks, are
+			# classified and delivered to separate mailboxes and/or marked with a keyword:
+			# $newsletter, $notification or $social. Classification is independent of junk
+			# filtering: messages classified as junk are still delivered to the Junk mailbox
+			# or rejected. Only messages that would be delivered to the default mailbox of the
+			# destination are categorized, not messages matched by rulesets, delivered to the
+			# screening mailbox, or to reporting addresses. (optional)
+			Categories:
+
+				# Newsletters, marketing and mailing list messages, recognized by a List-Id or
+				# List-Unsubscribe header, or a Precedence header with value bulk or list.
+				# (optional)
+				Newsletters:
+
+					# Mailbox to deliver messages in this category to, created when needed. If empty,
+					# messages are delivered to the default mailbox, only marked with the keyword of
+					# the category. (optional)
+					Mailbox:
+
+					# Sender domains whose messages are always in this category, also matching their
+					# subdomains, regardless of message headers. Only if the domain is verified,
+					# through the DMARC-aligned message From address, or a DKIM signature. Domains
+					# take precedence over the header-based heuristics of all categories. E.g.
+					# github.com for notifications. (optional)
+					Domains:
+						-
+
+				# Automated messages like receipts, alerts and account notifications, recognized
+				# by an Auto-Submitted header (other than "no"), also when the message has mailing
+				# list headers, or by a sender address without mailing list headers with a
+				# localpart like noreply, no-reply, donotreply, notifications or alerts.
+				# (optional)
+				Notifications:
+
+					# Mailbox to deliver messages in this category to, created when needed. If empty,
+					# messages are delivered to the default mailbox, only marked with the keyword of
+					# the category. (optional)
+					Mailbox:
+
+					# Sender domains whose messages are always in this category, also matching their
+					# subdomains, regardless of message headers. Only if the domain is verified,
+					# through the DMARC-aligned message From address, or a DKIM signature. Domains
+					# take precedence over the header-based heuristics of all categories. E.g.
+					# github.com for notifications. (optional)
+					Domains:
+						-
+
+				# Updates from social networks, recognized by the sender domain only. Messages
+				# from the following domains (and subdomains) are in this category when no Domains
+				# are configured: facebookmail.com, instagram.com, linkedin.com, pinterest.com,
+				# reddit.com, tiktok.com, twitter.com, x.com. (optional)
+				Social:
+
+					# Mailbox to deliver messages in this category to, created when needed. If empty,
+					# messages are delivered to the default mailbox, only marked with the keyword of
+					# the category. (optional)
+					Mailbox:
+
+					# Sender domains whose messages are always in this category, also matching their
+					# subdomains, regardless of message headers. Only if the domain is verified,
+					# through the DMARC-aligned message From address, or a DKIM signature. Domains
+					# take precedence over the header-based heuristics of all categories. E.g.
+					# github.com for notifications. (optional)
+					Domains:
+						-
+
 			# Maximum number of outgoing messages for this account in a 24 hour window. This
 			# limits the damage to recipients and the reputation of this mail server in case
 			# of account compromise. Default 1000. (optional)
@@ -1293,6 +2591,43 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 			# (optional)
 			NoCustomPassword: false
 
+			# Requirements for passwords set by the account, password expiry, and lockout
+			# after repeated failed login attempts. (optional)
+			PasswordPolicy:
+
+				# Minimum number of characters for passwords the account sets through the account
+				# web interface. Passwords are always at least 8 characters. (optional)
+				MinLength: 0
+
+				# Minimum estimated strength in bits of passwords the account sets through the
+				# account web interface, based on the length and the kinds of characters. E.g. 50.
+				# Randomly generated passwords have around 75 bits. (optional)
+				MinEntropy: 0
+
+				# If non-zero, passwords expire after this period, and login attempts with an
+				# expired password are rejected on all protocols except the account web interface,
+				# where a new password can be set. E.g. 2160h (90 days). Passwords set with older
+				# versions of mox have no known age and don't expire automatically, but the admin
+				# can require a password change. (optional)
+				MaxAge: 0s
+
+				# If non-zero, after this many consecutive failed login attempts for the account,
+				# on any protocol, login attempts are rejected during LockoutPeriod, even with the
+				# correct password. (optional)
+				LockoutFailures: 0
+
+				# Period during which login attempts are rejected after LockoutFailures failed
+				# attempts. Default 15m. (optional)
+				LockoutPeriod: 0s
+
+			# If set, logins to the web interfaces require a second factor, a time-based
+			# one-time password (TOTP) from an authenticator app. Until the account has
+			# configured TOTP, it can only login to the account web interface to set it up.
+			# IMAP/SMTP clients and other non-web protocols must use app passwords instead of
+			# the account password. Also required for accounts of a domain that requires
+			# two-factor authentication. (optional)
+			RequireTwoFactor: false
+
 			# Routes for delivering outgoing messages through the queue. Each delivery attempt
 			# evaluates these account routes, domain routes and finally global routes. The
 			# transport of the first matching route is used in the delivery attempt. If no
@@ -1317,6 +2652,109 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 					MinimumAttempts: 0
 					Transport:
 
+			# Changes to the message header of outgoing messages submitted by this account,
+			# made during submission before DKIM signing. Applied after rules of the domain of
+			# the message From address. (optional)
+			OutgoingHeaderRules:
+
+				# Keys of header fields to remove, case-insensitive. A trailing "*" matches any
+				# suffix, e.g. "X-Internal-*". (optional)
+				Remove:
+					-
+
+				# If set, the display name in the From header is replaced with this value. The
+				# text "{name}" is replaced with the original display name, e.g. "{name} (Example
+				# Corp)". (optional)
+				FromDisplayName:
+
+				# If set, the Reply-To header is set to this address, replacing any Reply-To
+				# header in the message. E.g. "Support <support@example.com>". (optional)
+				ReplyTo:
+
+				# Header fields to add, in the form "Key: value", e.g. an organization disclaimer.
+				# Existing header fields with the same key are removed. Non-ASCII text is encoded.
+				# (optional)
+				Add:
+					-
+
+			# Footer, e.g. a disclaimer, added to the text of outgoing messages submitted by
+			# this account. Takes precedence over a footer of the domain of the message From
+			# address. (optional)
+			OutgoingFooter:
+
+				# Lines of text for the footer, added to text/plain parts. Can contain non-ASCII
+				# characters.
+				Text:
+					-
+
+				# HTML for the footer, added to text/html parts before the closing body tag. If
+				# empty, the HTML footer is generated from the text lines. (optional)
+				HTML:
+
+			# Identities for sending messages with webmail, each with a From address, display
+			# name and signature. The addresses must be allowed as message From address for
+			# the account. If no identity is configured, the account addresses are used with
+			# the account signature. (optional)
+			Identities:
+				-
+
+					# Email address for the message From header. Must be an address the account is
+					# allowed to send as.
+					Address:
+
+					# Display name for the message From header. If empty, the full name of the account
+					# or destination is used. (optional)
+					DisplayName:
+
+					# Lines of text for the signature, added to new messages and replies composed with
+					# this identity. (optional)
+					Signature:
+						-
+
+					# HTML for the signature. If set, messages sent with this identity get an HTML
+					# version of the text with this signature, in addition to the plain text version.
+					# (optional)
+					SignatureHTML:
+
+					# How to quote the message when replying with this identity: empty for the account
+					# default, "bottom" to reply below the quoted text, or "top" to reply above the
+					# quoted text. (optional)
+					ReplyQuoting:
+
+					# Default Reply-To address for messages composed with this identity. (optional)
+					ReplyTo:
+
+					# Whether this identity is selected by default when composing new messages. At
+					# most one identity can be the default. (optional)
+					Default: false
+
+			# Access to mailboxes of this account given to other accounts, e.g. for sharing a
+			# support mailbox within a team. The other accounts can open the mailboxes in the
+			# webmail, and possibly send messages with the addresses of this account.
+			# (optional)
+			Delegations:
+				-
+
+					# Account that is given access.
+					Account:
+
+					# Names of mailboxes the account is given access to. Child mailboxes are not
+					# included. Access is by name: if a mailbox is renamed, access to it ends until
+					# the delegation is updated with the new name, and a new mailbox created with the
+					# old name is accessible.
+					Mailboxes:
+						-
+
+					# If set, the account can also mark messages as (un)read, move messages between
+					# the mailboxes and delete messages. Otherwise access is read-only. (optional)
+					Write: false
+
+					# Whether the account can send messages with the addresses of this account as
+					# message From address: empty for not allowed, "as" to send as this account, or
+					# "onbehalf" to send on behalf of this account, with a Sender header with the
+					# address of the account sending the message. (optional)
+					Send:
+
 	# Redirect all requests from domain (key) to domain (value). Always redirects to
 	# HTTPS. For plain HTTP redirects, use a WebHandler with a WebRedirect. (optional)
 	WebDomainRedirects:
@@ -1478,6 +2916,80 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 			MinimumAttempts: 0
 			Transport:
 
+	# Schedules for retrying delivery of outgoing messages from the queue after
+	# temporary failures. The first matching schedule is used. If no schedule matches,
+	# which is the default with no configured schedules, deliveries are attempted
+	# after 7.5m, 15m, 30m, 1h, 2h, 4h and 8h, giving up after 8 attempts. (optional)
+	RetrySchedules:
+		-
+
+			# Matches if the envelope to domain matches one of the configured domains, or if
+			# the list is empty. If a domain starts with a dot, prefixes of the domain also
+			# match. (optional)
+			ToDomain:
+				-
+
+			# Matches if empty or if the message is of this class: "regular" for all messages
+			# except reports, "report" for outgoing DMARC and TLS reports. (optional)
+			Class:
+
+			# Matches if zero or if the priority of the message, from -9 to 9 with 0 for
+			# normal priority, e.g. set with the SMTP MT-PRIORITY extension, is at least this
+			# value. For example, set to 1 for a schedule for high priority messages.
+			# (optional)
+			MinPriority: 0
+
+			# Matches if zero or if the priority of the message is at most this value. For
+			# example, set to -1 for a schedule for low priority messages. (optional)
+			MaxPriority: 0
+
+			# Time to wait after a failed delivery attempt before the next attempt, for each
+			# attempt. The last interval is used for any further attempts. Default is 7m30s,
+			# doubled after each attempt. A small random jitter is added to each interval.
+			# (optional)
+			Intervals:
+				- 0s
+
+			# Number of delivery attempts after which delivery fails permanently and a DSN is
+			# sent to the sender. Default is 8. A maximum number of attempts set explicitly
+			# for a message, e.g. through the webapi, takes precedence. (optional)
+			MaxAttempts: 0
+
+			# If non-zero, delivery fails permanently once the message has been in the queue
+			# for this long, even when not all attempts have been made. The last attempt is
+			# scheduled at the moment the message reaches this age. (optional)
+			MaxAge: 0s
+
+	# TLS policies for direct delivery of outgoing messages from the queue to
+	# recipient domains, overriding the behaviour based on MTA-STS and DANE. The first
+	# matching policy is used. Delivery attempts that do not satisfy the policy fail
+	# temporarily. If no policy matches, which is the default with no configured
+	# policies, STARTTLS is opportunistic unless required by MTA-STS or DANE, with
+	# fallback to plain text for failing TLS connections. (optional)
+	TLSPolicies:
+		-
+
+			# Matches if the envelope to domain matches one of the configured domains. If a
+			# domain starts with a dot, prefixes of the domain also match.
+			ToDomain:
+				-
+
+			# Empty to keep the default behaviour. "verify" requires STARTTLS with a
+			# certificate that is valid for the host name according to the WebPKI (in addition
+			# to DANE, if present), regardless of MTA-STS. "required" requires STARTTLS
+			# without verifying the certificate. "verify" and "required" also apply to
+			# messages with a "TLS-Required: No" header. "optional" allows fallback to plain
+			# text and ignores failing MTA-STS and DANE verification, like a "TLS-Required:
+			# No" header, but not for messages sent with REQUIRETLS. (optional)
+			Mode:
+
+			# If non-empty, the TLS certificate of the remote server must have one of these
+			# SHA-256 fingerprints, of the DER-encoded leaf certificate, in hexadecimal,
+			# optionally with colons. Implies STARTTLS is required. Cannot be combined with
+			# mode "optional". (optional)
+			CertificateSHA256:
+				-
+
 	# DNS blocklists to periodically check with if IPs we send from are present,
 	# without using them for checking incoming deliveries.. Also see DNSBLs in SMTP
 	# listeners in mox.conf, which specifies DNSBLs to use both for incoming
@@ -1486,6 +2998,28 @@ See https://pkg.go.dev/github.com/mjl-/sconf for details.
 	MonitorDNSBLs:
 		-
 
+	# Named users for the admin web interface, each with a role limiting what they can
+	# do. Logins with only the admin password from mox.conf (AdminPasswordFile) have
+	# full access. Manage with the "mox config adminuser" subcommands, or in the admin
+	# web interface. (optional)
+	AdminUsers:
+		x:
+
+			# Role of the user: admin for full access; domainadmin for managing the domains in
+			# Domains, with their accounts (by the default domain of the account), addresses
+			# and aliases; auditor for read-only access, without access to secrets like
+			# credentials in the configuration files and webhook authorization headers and
+			# signing keys of accounts. Roles only apply to the admin web interface, commands
+			# through the ctl socket, such as the mox subcommands, always have full access.
+			Role:
+
+			# Domains a domainadmin can manage. (optional)
+			Domains:
+				-
+
+			# Bcrypt hash of the password. Set with "mox config adminuser setpassword".
+			PasswordHash:
+
 # Examples
 
 Mox includes configuration files to illustrate common setups. You can see these
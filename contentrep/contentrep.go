@@ -0,0 +1,331 @@
+// Package contentrep checks URLs and attachments of messages against reputation
+// sources: local lists of domains and attachment hashes, and DNS-based block
+// lists like SURBL and URIBL.
+//
+// Domains of URLs are looked up in a URI block list by their organizational
+// domain: For www.example.com in zone multi.surbl.example, the name
+// "example.com.multi.surbl.example" is looked up with a DNS "A" lookup. If it
+// exists with an address in 127.0.0.0/8 (other than 127.0.0.1, which URI block
+// lists return for refused queries, and 127.255.255.0/24, used for errors), the
+// domain is listed. Attachments are looked up in hash block lists by their
+// lower-case hexadecimal SHA-256 hash of their decoded content, in the same way.
+//
+// Results of DNS lookups are cached.
+package contentrep
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/publicsuffix"
+	"github.com/mjl-/mox/stub"
+)
+
+var (
+	MetricLookup stub.HistogramVec = stub.HistogramVecIgnore{}
+	MetricCache  stub.CounterVec   = stub.CounterVecIgnore{}
+	MetricListed stub.CounterVec   = stub.CounterVecIgnore{}
+)
+
+var ErrDNS = errors.New("contentrep: dns error") // Temporary error.
+
+// Content holds the URL domains and attachment hashes found in a message.
+type Content struct {
+	Hosts  []dns.Domain // Unique host names in URLs, in order of appearance.
+	Hashes []string     // Lower-case hexadecimal SHA-256 hashes of decoded attachments.
+}
+
+// Sources are the reputation sources to check content against.
+type Sources struct {
+	URIBLs         []dns.Domain        // Zones of URI block lists.
+	HashBLs        []dns.Domain        // Zones of attachment hash block lists.
+	BlockedDomains []dns.Domain        // Blocked domains, including their subdomains.
+	Hashes         map[string]struct{} // Blocked attachment hashes.
+	MaxURLs        int                 // Maximum number of URL domains to look up in block lists. Default 20.
+	CacheTTL       time.Duration       // Duration to cache DNS lookup results. Default 1h.
+}
+
+// Listing is a URL domain or attachment hash found in a reputation source.
+type Listing struct {
+	Kind   string // "url" or "attachment".
+	Value  string // Domain or hash that is listed.
+	Source string // "local", or the zone of the block list.
+}
+
+func (l Listing) String() string {
+	return fmt.Sprintf("%s %s listed in %s", l.Kind, l.Value, l.Source)
+}
+
+// Maximum number of bytes of a text part to scan for URLs.
+const maxTextSize = 1024 * 1024
+
+var urlRegexp = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'()\[\]{}\\]+`)
+
+// Extract returns the host names of URLs in the text and HTML parts of a message,
+// and the hashes of its attachments. Nested messages are included.
+func Extract(elog *slog.Logger, p *message.Part) (Content, error) {
+	log := mlog.New("contentrep", elog)
+	var c Content
+	seen := map[dns.Domain]bool{}
+	err := extract(log, p, &c, seen)
+	return c, err
+}
+
+func extract(log mlog.Log, p *message.Part, c *Content, seen map[dns.Domain]bool) error {
+	if p.Message != nil {
+		// Forwarded messages.
+		if err := p.SetMessageReaderAt(); err != nil {
+			return fmt.Errorf("setting reader on nested message: %w", err)
+		}
+		return extract(log, p.Message, c, seen)
+	}
+	if len(p.Parts) > 0 {
+		for i := range p.Parts {
+			if err := extract(log, &p.Parts[i], c, seen); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	disposition, filename, err := p.DispositionFilename()
+	if err != nil {
+		log.Debugx("parsing content-disposition, continuing", err)
+	}
+	ct := p.MediaType + "/" + p.MediaSubType
+	isText := ct == "/" || ct == "TEXT/PLAIN" || ct == "TEXT/HTML"
+	if isText && !strings.EqualFold(disposition, "attachment") && filename == "" {
+		return extractURLs(p.ReaderUTF8OrBinary(), c, seen)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, p.Reader()); err != nil {
+		return fmt.Errorf("hashing attachment: %w", err)
+	}
+	c.Hashes = append(c.Hashes, hex.EncodeToString(h.Sum(nil)))
+	return nil
+}
+
+func extractURLs(r io.Reader, c *Content, seen map[dns.Domain]bool) error {
+	scanner := bufio.NewScanner(io.LimitReader(r, maxTextSize))
+	scanner.Buffer(make([]byte, 0, 16*1024), 64*1024)
+	for scanner.Scan() {
+		for _, s := range urlRegexp.FindAllString(scanner.Text(), -1) {
+			d, ok := urlHost(s)
+			if ok && !seen[d] {
+				seen[d] = true
+				c.Hosts = append(c.Hosts, d)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil && err != bufio.ErrTooLong {
+		return fmt.Errorf("reading text: %w", err)
+	}
+	return nil
+}
+
+// urlHost returns the host of a URL matched by urlRegexp. URLs with IP addresses
+// or invalid host names are ignored.
+func urlHost(s string) (dns.Domain, bool) {
+	if i := strings.Index(s, "://"); i >= 0 {
+		s = s[i+3:]
+	}
+	if i := strings.IndexAny(s, "/?#"); i >= 0 {
+		s = s[:i]
+	}
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		s = s[i+1:]
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	s = strings.TrimRight(s, ".,;:!")
+	if !strings.Contains(s, ".") || net.ParseIP(s) != nil {
+		return dns.Domain{}, false
+	}
+	d, err := dns.ParseDomain(s)
+	if err != nil {
+		return dns.Domain{}, false
+	}
+	return d, true
+}
+
+// Check looks up the content in the reputation sources. Local lists are checked
+// first. Block lists are only queried for content not listed locally, and
+// lookups stop at the first listing. Errors for DNS lookups are returned, along
+// with the listings found, as ErrDNS.
+func Check(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, src Sources, c Content) (listings []Listing, rerr error) {
+	log := mlog.New("contentrep", elog)
+
+	defer func() {
+		for _, l := range listings {
+			source := "dns"
+			if l.Source == "local" {
+				source = "local"
+			}
+			MetricListed.IncLabels(l.Kind, source)
+		}
+	}()
+
+	for _, h := range c.Hosts {
+		for _, bd := range src.BlockedDomains {
+			if h == bd || strings.HasSuffix(h.ASCII, "."+bd.ASCII) {
+				listings = append(listings, Listing{"url", h.Name(), "local"})
+				break
+			}
+		}
+	}
+	for _, h := range c.Hashes {
+		if _, ok := src.Hashes[h]; ok {
+			listings = append(listings, Listing{"attachment", h, "local"})
+		}
+	}
+	if len(listings) > 0 {
+		return listings, nil
+	}
+
+	maxURLs := src.MaxURLs
+	if maxURLs == 0 {
+		maxURLs = 20
+	}
+	ttl := src.CacheTTL
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+
+	var orgDomains []dns.Domain
+	seen := map[dns.Domain]bool{}
+	for _, h := range c.Hosts {
+		d := publicsuffix.Lookup(ctx, log.Logger, h)
+		if !seen[d] && len(orgDomains) < maxURLs {
+			seen[d] = true
+			orgDomains = append(orgDomains, d)
+		}
+	}
+
+	var lookupErr error
+	check := func(kind string, zones []dns.Domain, values []string) bool {
+		for _, v := range values {
+			for _, zone := range zones {
+				listed, err := lookup(ctx, log, resolver, zone, v, ttl)
+				if err != nil {
+					log.Debugx("block list lookup", err, slog.Any("zone", zone), slog.String("name", v))
+					lookupErr = err
+				} else if listed {
+					listings = append(listings, Listing{kind, v, zone.Name()})
+					return true
+				}
+			}
+		}
+		return false
+	}
+	names := make([]string, len(orgDomains))
+	for i, d := range orgDomains {
+		names[i] = d.ASCII
+	}
+	if !check("url", src.URIBLs, names) {
+		check("attachment", src.HashBLs, c.Hashes)
+	}
+	return listings, lookupErr
+}
+
+type cacheKey struct {
+	zone string
+	name string
+}
+
+type cacheEntry struct {
+	listed  bool
+	expires time.Time
+}
+
+// Maximum number of cached lookup results. When full, expired entries are
+// removed, and if still full, all entries.
+const maxCacheEntries = 10000
+
+var cache = struct {
+	sync.Mutex
+	m map[cacheKey]cacheEntry
+}{m: map[cacheKey]cacheEntry{}}
+
+// ResetCache removes all cached lookup results.
+func ResetCache() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.m = map[cacheKey]cacheEntry{}
+}
+
+// lookup returns whether name (a domain or hash) is listed in the block list zone.
+func lookup(ctx context.Context, log mlog.Log, resolver dns.Resolver, zone dns.Domain, name string, ttl time.Duration) (rlisted bool, rerr error) {
+	key := cacheKey{zone.ASCII, name}
+	now := time.Now()
+	cache.Lock()
+	e, ok := cache.m[key]
+	cache.Unlock()
+	if ok && now.Before(e.expires) {
+		MetricCache.IncLabels("hit")
+		return e.listed, nil
+	}
+	MetricCache.IncLabels("miss")
+
+	start := time.Now()
+	defer func() {
+		result := "pass"
+		if rerr != nil {
+			result = "temperror"
+		} else if rlisted {
+			result = "fail"
+		}
+		MetricLookup.ObserveLabels(float64(time.Since(start))/float64(time.Second), zone.Name(), result)
+		log.Debugx("block list lookup result", rerr,
+			slog.Any("zone", zone),
+			slog.String("name", name),
+			slog.Bool("listed", rlisted),
+			slog.Duration("duration", time.Since(start)))
+	}()
+
+	ips, _, err := dns.WithPackage(resolver, "contentrep").LookupIP(ctx, "ip4", name+"."+zone.ASCII+".")
+	if dns.IsNotFound(err) {
+		rlisted = false
+	} else if err != nil {
+		return false, fmt.Errorf("%w: %s", ErrDNS, err)
+	} else {
+		for _, ip := range ips {
+			ip = ip.To4()
+			if ip == nil || ip[0] != 127 || ip.Equal(net.IPv4(127, 0, 0, 1)) || ip[1] == 255 && ip[2] == 255 {
+				// Not a listing, but e.g. an indication the query was refused. Not cached.
+				return false, fmt.Errorf("%w: unexpected result %s", ErrDNS, ip)
+			}
+		}
+		rlisted = true
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	if len(cache.m) >= maxCacheEntries {
+		for k, e := range cache.m {
+			if !now.Before(e.expires) {
+				delete(cache.m, k)
+			}
+		}
+		if len(cache.m) >= maxCacheEntries {
+			cache.m = map[cacheKey]cacheEntry{}
+		}
+	}
+	cache.m[key] = cacheEntry{rlisted, now.Add(ttl)}
+	return rlisted, nil
+}
@@ -0,0 +1,112 @@
+package contentrep
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+)
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestContentReputation(t *testing.T) {
+	ctx := context.Background()
+	log := mlog.New("contentrep", nil)
+
+	msg := strings.ReplaceAll(`From: <remote@example.org>
+To: <mjl@mox.example>
+Subject: test
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=x
+
+--x
+Content-Type: text/plain
+
+Visit https://www.example.com/path?x=1, or www.Example.com.
+Also http://user@sub.listed.example:8080/ and https://192.0.2.1/ignored.
+--x
+Content-Type: text/html
+
+<a href="https://shop.example.net/buy">buy</a>
+--x
+Content-Type: application/octet-stream
+Content-Disposition: attachment; filename=data.bin
+Content-Transfer-Encoding: base64
+
+aGVsbG8=
+--x--
+`, "\n", "\r\n")
+	p, err := message.EnsurePart(log.Logger, false, strings.NewReader(msg), int64(len(msg)))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	c, err := Extract(log.Logger, &p)
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	hash := sha256.Sum256([]byte("hello"))
+	hashHex := hex.EncodeToString(hash[:])
+	tcompare(t, c.Hosts, []dns.Domain{{ASCII: "www.example.com"}, {ASCII: "sub.listed.example"}, {ASCII: "shop.example.net"}})
+	tcompare(t, c.Hashes, []string{hashHex})
+
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"listed.example.uribl.example.":  {"127.0.0.2"},
+			"example.net.refused.example.":   {"127.0.0.1"},
+			hashHex + ".hashbl.example.":     {"127.0.0.2"},
+			"www.example.com.uribl.example.": {"127.0.0.2"}, // Not looked up, only organizational domains are.
+		},
+	}
+
+	// Local lists.
+	l, err := Check(ctx, log.Logger, resolver, Sources{BlockedDomains: []dns.Domain{{ASCII: "listed.example"}}}, c)
+	tcompare(t, err, nil)
+	tcompare(t, l, []Listing{{"url", "sub.listed.example", "local"}})
+	l, err = Check(ctx, log.Logger, resolver, Sources{BlockedDomains: []dns.Domain{{ASCII: "isted.example"}}, Hashes: map[string]struct{}{hashHex: {}}}, c)
+	tcompare(t, err, nil)
+	tcompare(t, l, []Listing{{"attachment", hashHex, "local"}})
+
+	// URI and hash block lists.
+	uribl := dns.Domain{ASCII: "uribl.example"}
+	hashbl := dns.Domain{ASCII: "hashbl.example"}
+	l, err = Check(ctx, log.Logger, resolver, Sources{URIBLs: []dns.Domain{uribl}, HashBLs: []dns.Domain{hashbl}}, c)
+	tcompare(t, err, nil)
+	tcompare(t, l, []Listing{{"url", "listed.example", "uribl.example"}})
+	l, err = Check(ctx, log.Logger, resolver, Sources{HashBLs: []dns.Domain{hashbl}}, c)
+	tcompare(t, err, nil)
+	tcompare(t, l, []Listing{{"attachment", hashHex, "hashbl.example"}})
+
+	// Limited number of URL domains.
+	l, err = Check(ctx, log.Logger, resolver, Sources{URIBLs: []dns.Domain{uribl}, MaxURLs: 1}, c)
+	tcompare(t, err, nil)
+	tcompare(t, len(l), 0)
+
+	// Refused queries are not listings.
+	l, err = Check(ctx, log.Logger, resolver, Sources{URIBLs: []dns.Domain{{ASCII: "refused.example"}}}, c)
+	if !errors.Is(err, ErrDNS) {
+		t.Fatalf("got err %v, expected ErrDNS", err)
+	}
+	tcompare(t, len(l), 0)
+
+	// Results are cached.
+	resolver.A = nil
+	l, err = Check(ctx, log.Logger, resolver, Sources{URIBLs: []dns.Domain{uribl}}, c)
+	tcompare(t, err, nil)
+	tcompare(t, l, []Listing{{"url", "listed.example", "uribl.example"}})
+	ResetCache()
+	l, err = Check(ctx, log.Logger, resolver, Sources{URIBLs: []dns.Domain{uribl}}, c)
+	tcompare(t, err, nil)
+	tcompare(t, len(l), 0)
+}
@@ -13,7 +13,6 @@ import (
 	"maps"
 	"net"
 	"os"
-	"path/filepath"
 	"runtime/debug"
 	"slices"
 	"strconv"
@@ -44,6 +43,11 @@ type ctl struct {
 	r    *bufio.Reader // Set for first reader.
 	x    any           // If set, errors are handled by calling panic(x) instead of log.Fatal.
 	log  mlog.Log      // If set, along with x, logging is done here.
+
+	// Server-side, for the audit log: parameters read for the current command, only
+	// recorded if not nil, and the error written.
+	args   []string
+	errmsg string
 }
 
 // xctl opens a ctl connection.
@@ -68,6 +72,7 @@ func (c *ctl) xerror(msg string) {
 		log.Fatalln(msg)
 	}
 	c.log.Debugx("ctl error", fmt.Errorf("%s", msg), slog.String("cmd", c.cmd))
+	c.errmsg = msg
 	c.xwrite(msg)
 	panic(c.x)
 }
@@ -83,6 +88,7 @@ func (c *ctl) xcheck(err error, msg string) {
 		log.Fatalf("%s: %s", msg, err)
 	}
 	c.log.Debugx(msg, err, slog.String("cmd", c.cmd))
+	c.errmsg = fmt.Sprintf("%s: %s", msg, err)
 	fmt.Fprintf(c.conn, "%s: %s\n", msg, err)
 	panic(c.x)
 }
@@ -94,7 +100,11 @@ func (c *ctl) xread() string {
 	}
 	line, err := c.r.ReadString('\n')
 	c.xcheck(err, "read from ctl")
-	return strings.TrimSuffix(line, "\n")
+	line = strings.TrimSuffix(line, "\n")
+	if c.args != nil {
+		c.args = append(c.args, line)
+	}
+	return line
 }
 
 // Read a line. If not "ok", the string is interpreted as an error.
@@ -279,6 +289,10 @@ func (s *ctlreader) xcheck(err error, msg string) {
 }
 
 // servectl handles requests on the unix domain socket "ctl", e.g. for graceful shutdown, local mail delivery.
+//
+// Admin user roles are not enforced for ctl commands. The socket is in the data
+// directory, only accessible to the mox user and group, which can already access
+// the configuration files and databases.
 func servectl(ctx context.Context, cid int64, log mlog.Log, conn net.Conn, shutdown func()) {
 	log.Debug("ctl connection")
 
@@ -312,11 +326,86 @@ func xparseJSON(xctl *ctl, s string, v any) {
 	xctl.xcheck(err, "parsing from ctl as json")
 }
 
+// ctlAudit holds the commands that are recorded in the audit log, with the kind
+// of event, and the index of the parameter with the account name or -1. Other
+// parameters are not recorded, they can hold passwords.
+var ctlAudit = map[string]struct {
+	kind         string
+	accountIndex int
+}{
+	"setaccountpassword":   {store.AuditPassword, 0},
+	"queueholdrulesadd":    {store.AuditQueue, -1},
+	"queueholdrulesremove": {store.AuditQueue, -1},
+	"queueholdrulesresume": {store.AuditQueue, -1},
+	"queueholdset":         {store.AuditQueue, -1},
+	"queueschedule":        {store.AuditQueue, -1},
+	"queuetransport":       {store.AuditQueue, -1},
+	"queuerequiretls":      {store.AuditQueue, -1},
+	"queuefail":            {store.AuditQueue, -1},
+	"queuedrop":            {store.AuditQueue, -1},
+	"queuedeadrecipient":   {store.AuditQueue, -1},
+	"queuedeadrequeue":     {store.AuditQueue, -1},
+	"queuedeadremove":      {store.AuditQueue, -1},
+	"queuehookschedule":    {store.AuditQueue, -1},
+	"queuehookcancel":      {store.AuditQueue, -1},
+	"queuesuppressadd":     {store.AuditQueue, 0},
+	"queuesuppressremove":  {store.AuditQueue, 0},
+	"domainadd":            {store.AuditConfig, -1},
+	"domainrm":             {store.AuditConfig, -1},
+	"domaindisabled":       {store.AuditConfig, -1},
+	"accountadd":           {store.AuditAccount, 0},
+	"accountrm":            {store.AuditAccount, 0},
+	"accountimport":        {store.AuditAccount, -1},
+	"junkexport":           {store.AuditExport, 0},
+	"junkimport":           {store.AuditConfig, 0},
+	"accountdisabled":      {store.AuditConfig, 0},
+	"accountenable":        {store.AuditConfig, 0},
+	"adminuseradd":         {store.AuditConfig, -1},
+	"adminuserupdate":      {store.AuditConfig, -1},
+	"adminusersetpassword": {store.AuditPassword, -1},
+	"adminuserrm":          {store.AuditConfig, -1},
+	"provisioningtokenadd": {store.AuditPassword, -1},
+	"provisioningtokenrm":  {store.AuditPassword, -1},
+	"tlspubkeyadd":         {store.AuditPassword, -1},
+	"tlspubkeyrm":          {store.AuditPassword, -1},
+	"addressadd":           {store.AuditConfig, 1},
+	"addressrm":            {store.AuditConfig, -1},
+	"aliasadd":             {store.AuditConfig, -1},
+	"aliasupdate":          {store.AuditConfig, -1},
+	"aliasrm":              {store.AuditConfig, -1},
+	"aliasaddaddr":         {store.AuditConfig, -1},
+	"aliasrmaddr":          {store.AuditConfig, -1},
+	"setloglevels":         {store.AuditConfig, -1},
+}
+
 func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 	log := xctl.log
+	xctl.args = nil
+	xctl.errmsg = ""
 	cmd := xctl.xread()
 	xctl.cmd = cmd
 	log.Info("ctl command", slog.String("cmd", cmd))
+	xctl.args = []string{}
+
+	if audit, ok := ctlAudit[cmd]; ok {
+		defer func() {
+			x := recover()
+			e := store.AuditEvent{Kind: audit.kind, Actor: "(ctl)", Protocol: "ctl", Action: cmd, Result: "ok"}
+			if x != nil {
+				e.Result = xctl.errmsg
+				if e.Result == "" {
+					e.Result = "error"
+				}
+			}
+			if audit.accountIndex >= 0 && audit.accountIndex < len(xctl.args) {
+				e.Account = xctl.args[audit.accountIndex]
+			}
+			store.AuditAdd(ctx, log, e)
+			if x != nil {
+				panic(x)
+			}
+		}()
+	}
 	switch cmd {
 	case "stop":
 		shutdown()
@@ -409,7 +498,7 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 				elems = append(elems, fmt.Sprintf("sender domain %q", hr.SenderDomain.Name()))
 			}
 			if hr.RecipientDomain != zerodom {
-				elems = append(elems, fmt.Sprintf("sender domain %q", hr.RecipientDomain.Name()))
+				elems = append(elems, fmt.Sprintf("recipient domain %q", hr.RecipientDomain.Name()))
 			}
 			if len(elems) == 0 {
 				fmt.Fprintf(xw, "id %d: all messages\n", hr.ID)
@@ -456,6 +545,21 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "remove hold rule")
 		xctl.xwriteok()
 
+	case "queueholdrulesresume":
+		/* protocol:
+		> "queueholdrulesresume"
+		> id
+		< "ok" or error
+		< count
+		*/
+		idstr := xctl.xread()
+		id, err := strconv.ParseInt(idstr, 10, 64)
+		xctl.xcheck(err, "parsing id")
+		count, err := queue.HoldRuleResume(ctx, log, id)
+		xctl.xcheck(err, "resume hold rule")
+		xctl.xwriteok()
+		xctl.xwrite(fmt.Sprintf("%d", count))
+
 	case "queuelist":
 		/* protocol:
 		> "queuelist"
@@ -699,6 +803,110 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "encode retired message")
 		xw.xclose()
 
+	case "queuedeadlist":
+		/* protocol:
+		> "queuedeadlist"
+		> filters as json
+		< "ok"
+		< stream
+		*/
+		filterline := xctl.xread()
+		var f queue.DeadFilter
+		xparseJSON(xctl, filterline, &f)
+		l, err := queue.DeadList(ctx, f)
+		xctl.xcheck(err, "listing dead-letter queue")
+		xctl.xwriteok()
+
+		xw := xctl.writer()
+		fmt.Fprintln(xw, "dead-letter messages:")
+		for _, dm := range l {
+			fmt.Fprintf(xw, "%5d %s failed %s from:%s to:%s attempts %d error %q\n", dm.ID, dm.Queued.Format(time.RFC3339), dm.Failed.Format(time.RFC3339), dm.Sender().LogString(), dm.Recipient().LogString(), dm.Attempts, dm.LastResult().Error)
+		}
+		if len(l) == 0 {
+			fmt.Fprint(xw, "(none)\n")
+		}
+		xw.xclose()
+
+	case "queuedeadprint":
+		/* protocol:
+		> "queuedeadprint"
+		> id
+		< "ok"
+		< stream
+		*/
+		idstr := xctl.xread()
+		id, err := strconv.ParseInt(idstr, 10, 64)
+		xctl.xcheck(err, "parsing id")
+		l, err := queue.DeadList(ctx, queue.DeadFilter{IDs: []int64{id}})
+		xctl.xcheck(err, "getting dead-letter messages")
+		if len(l) == 0 {
+			xctl.xcheck(errors.New("not found"), "getting dead-letter message")
+		}
+		xctl.xwriteok()
+		xw := xctl.writer()
+		enc := json.NewEncoder(xw)
+		enc.SetIndent("", "\t")
+		err = enc.Encode(l[0])
+		xctl.xcheck(err, "encode dead-letter message")
+		xw.xclose()
+
+	case "queuedeaddump":
+		/* protocol:
+		> "queuedeaddump"
+		> id
+		< "ok" or error
+		< stream
+		*/
+		idstr := xctl.xread()
+		id, err := strconv.ParseInt(idstr, 10, 64)
+		xctl.xcheck(err, "parsing id")
+		mr, err := queue.OpenDeadMessage(ctx, id)
+		xctl.xcheck(err, "opening message")
+		defer func() {
+			err := mr.Close()
+			log.Check(err, "closing message from dead-letter queue")
+		}()
+		xctl.xwriteok()
+		xctl.xstreamfrom(mr)
+
+	case "queuedeadrecipient":
+		/* protocol:
+		> "queuedeadrecipient"
+		> id
+		> address
+		< "ok" or error
+		*/
+		idstr := xctl.xread()
+		addrstr := xctl.xread()
+		id, err := strconv.ParseInt(idstr, 10, 64)
+		xctl.xcheck(err, "parsing id")
+		addr, err := smtp.ParseAddress(addrstr)
+		xctl.xcheck(err, "parsing address")
+		err = queue.DeadRecipientSet(ctx, log, id, addr.Path())
+		xctl.xcheck(err, "changing recipient")
+		xctl.xwriteok()
+
+	case "queuedeadrequeue", "queuedeadremove":
+		/* protocol:
+		> "queuedeadrequeue" or "queuedeadremove"
+		> filters as json
+		< "ok" or error
+		< count
+		*/
+		filterline := xctl.xread()
+		var f queue.DeadFilter
+		xparseJSON(xctl, filterline, &f)
+		var count int
+		var err error
+		if cmd == "queuedeadrequeue" {
+			count, err = queue.DeadRequeue(ctx, log, f)
+		} else {
+			count, err = queue.DeadRemove(ctx, log, f)
+		}
+		xctl.xcheck(err, "processing messages in dead-letter queue")
+		xctl.xwriteok()
+		xctl.xwrite(fmt.Sprintf("%d", count))
+
 	case "queuehooklist":
 		/* protocol:
 		> "queuehooklist"
@@ -1045,6 +1253,75 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "adding account")
 		xctl.xwriteok()
 
+	case "accountimport":
+		/* protocol:
+		> "accountimport"
+		> accounts as json
+		> dryrun (true/false)
+		> requirechange (true/false)
+		< "ok" or error
+		< results as json
+		< "ok" or error
+		*/
+		var accounts []admin.AccountImport
+		xparseJSON(xctl, xctl.xread(), &accounts)
+		dryRun := xctl.xread() == "true"
+		requireChange := xctl.xread() == "true"
+		results, err := admin.AccountsImport(ctx, accounts, dryRun, requireChange)
+		if results == nil {
+			xctl.xcheck(err, "importing accounts")
+		}
+		xctl.xwriteok()
+		buf, xerr := json.Marshal(results)
+		xctl.xcheck(xerr, "marshal results")
+		xctl.xwrite(string(buf))
+		xctl.xcheck(err, "importing accounts")
+		xctl.xwriteok()
+
+	case "junkexport":
+		/* protocol:
+		> "junkexport"
+		> account
+		< "ok" or error
+		< stream
+		*/
+		account := xctl.xread()
+		acc, err := store.OpenAccount(log, account, false)
+		xctl.xcheck(err, "open account")
+		defer func() {
+			err := acc.Close()
+			log.Check(err, "closing account")
+		}()
+		sd, err := acc.SpamDataExport(ctx, log)
+		xctl.xcheck(err, "exporting junk filter and sender lists")
+		xctl.xwriteok()
+		xw := xctl.writer()
+		err = json.NewEncoder(xw).Encode(sd)
+		xctl.xcheck(err, "writing export")
+		xw.xclose()
+
+	case "junkimport":
+		/* protocol:
+		> "junkimport"
+		> account
+		> stream
+		< "ok" or error
+		*/
+		account := xctl.xread()
+		var b bytes.Buffer
+		xctl.xstreamto(&b)
+		var sd store.SpamData
+		xparseJSON(xctl, b.String(), &sd)
+		acc, err := store.OpenAccount(log, account, false)
+		xctl.xcheck(err, "open account")
+		defer func() {
+			err := acc.Close()
+			log.Check(err, "closing account")
+		}()
+		err = acc.SpamDataImport(ctx, log, sd)
+		xctl.xcheck(err, "importing junk filter and sender lists")
+		xctl.xwriteok()
+
 	case "accountrm":
 		/* protocol:
 		> "accountrm"
@@ -1115,6 +1392,154 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "enabling account")
 		xctl.xwriteok()
 
+	case "auditlogexport":
+		/* protocol:
+		> "auditlogexport"
+		> format, "json" or "syslog"
+		> filter as JSON
+		< "ok" or error
+		< stream
+		*/
+		format := xctl.xread()
+		var filter store.AuditFilter
+		xparseJSON(xctl, xctl.xread(), &filter)
+		if format != "json" && format != "syslog" {
+			xctl.xerror("unknown format")
+		}
+		xctl.xwriteok()
+		xw := xctl.writer()
+		bw := bufio.NewWriter(xw)
+		enc := json.NewEncoder(bw)
+		err := store.AuditForEach(ctx, filter, func(e store.AuditEvent) error {
+			if format == "json" {
+				return enc.Encode(e)
+			}
+			_, err := fmt.Fprintln(bw, e.Syslog(mox.Conf.Static.HostnameDomain.ASCII))
+			return err
+		})
+		log.Check(err, "exporting audit log")
+		err = bw.Flush()
+		xctl.xcheck(err, "flushing output")
+		xw.xclose()
+
+	case "adminuserlist":
+		/* protocol:
+		> "adminuserlist"
+		< "ok" or error
+		< stream
+		*/
+		xctl.xwriteok()
+		xw := xctl.writer()
+		users := mox.Conf.AdminUsers()
+		for _, name := range slices.Sorted(maps.Keys(users)) {
+			au := users[name]
+			fmt.Fprintf(xw, "%s\t%s\t%s\n", name, au.Role, strings.Join(au.Domains, ","))
+		}
+		xw.xclose()
+
+	case "adminuseradd":
+		/* protocol:
+		> "adminuseradd"
+		> name
+		> role
+		> domains as json
+		> password
+		< "ok" or error
+		*/
+		name := xctl.xread()
+		role := xctl.xread()
+		line := xctl.xread()
+		pw := xctl.xread()
+		var domains []string
+		xparseJSON(xctl, line, &domains)
+		err := admin.AdminUserAdd(ctx, name, role, domains, pw)
+		xctl.xcheck(err, "adding admin user")
+		xctl.xwriteok()
+
+	case "adminuserupdate":
+		/* protocol:
+		> "adminuserupdate"
+		> name
+		> role
+		> domains as json
+		< "ok" or error
+		*/
+		name := xctl.xread()
+		role := xctl.xread()
+		line := xctl.xread()
+		var domains []string
+		xparseJSON(xctl, line, &domains)
+		err := admin.AdminUserUpdate(ctx, name, role, domains)
+		xctl.xcheck(err, "updating admin user")
+		xctl.xwriteok()
+
+	case "adminusersetpassword":
+		/* protocol:
+		> "adminusersetpassword"
+		> name
+		> password
+		< "ok" or error
+		*/
+		name := xctl.xread()
+		pw := xctl.xread()
+		err := admin.AdminUserSetPassword(ctx, name, pw)
+		xctl.xcheck(err, "setting admin user password")
+		xctl.xwriteok()
+
+	case "adminuserrm":
+		/* protocol:
+		> "adminuserrm"
+		> name
+		< "ok" or error
+		*/
+		name := xctl.xread()
+		err := admin.AdminUserRemove(ctx, name)
+		xctl.xcheck(err, "removing admin user")
+		xctl.xwriteok()
+
+	case "provisioningtokenlist":
+		/* protocol:
+		> "provisioningtokenlist"
+		< "ok" or error
+		< stream
+		*/
+		tokens, err := store.ProvisioningTokenList(ctx)
+		xctl.xcheck(err, "listing provisioning tokens")
+		xctl.xwriteok()
+		xw := xctl.writer()
+		for _, pt := range tokens {
+			lastUsed := "-"
+			if !pt.LastUsed.IsZero() {
+				lastUsed = pt.LastUsed.Format(time.RFC3339)
+			}
+			fmt.Fprintf(xw, "%s\t%s\t%s\n", pt.Name, pt.Created.Format(time.RFC3339), lastUsed)
+		}
+		xw.xclose()
+
+	case "provisioningtokenadd":
+		/* protocol:
+		> "provisioningtokenadd"
+		> name
+		< "ok" or error
+		< token
+		*/
+		name := xctl.xread()
+		token, err := store.ProvisioningTokenAdd(ctx, name)
+		xctl.xcheck(err, "adding provisioning token")
+		xctl.xwriteok()
+		xctl.xwrite(token)
+
+	case "provisioningtokenrm":
+		/* protocol:
+		> "provisioningtokenrm"
+		> name
+		< "ok" or error
+		*/
+		name := xctl.xread()
+		err := store.ProvisioningTokenRemove(ctx, name)
+		xctl.xcheck(err, "removing provisioning token")
+		xctl.xwriteok()
+
 	case "tlspubkeylist":
 		/* protocol:
 		> "tlspubkeylist"
@@ -1203,6 +1628,21 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 		xctl.xcheck(err, "removing tls public key")
 		xctl.xwriteok()
 
+	case "tlscertsreload":
+		/* protocol:
+		> "tlscertsreload"
+		< "ok" or error
+		< stream
+		*/
+		reloaded, err := mox.ReloadTLSCerts(ctx, log)
+		xctl.xcheck(err, "reloading tls certificates")
+		xctl.xwriteok()
+		xw := xctl.writer()
+		for _, name := range reloaded {
+			fmt.Fprintf(xw, "%s\n", name)
+		}
+		xw.xclose()
+
 	case "addressadd":
 		/* protocol:
 		> "addressadd"
@@ -1447,65 +1887,8 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 				}
 			}()
 
-			// todo: can we retrain an account without holding a write lock? perhaps by writing a junkfilter to a new location, and staying informed of message changes while we go through all messages in the account?
-
-			acc.WithWLock(func() {
-				conf, _ := acc.Conf()
-				if conf.JunkFilter == nil {
-					xctl.xcheck(store.ErrNoJunkFilter, "looking for junk filter")
-				}
-
-				// Remove existing junk filter files.
-				basePath := mox.DataDirPath("accounts")
-				dbPath := filepath.Join(basePath, acc.Name, "junkfilter.db")
-				bloomPath := filepath.Join(basePath, acc.Name, "junkfilter.bloom")
-				err := os.Remove(dbPath)
-				log.Check(err, "removing old junkfilter database file", slog.String("path", dbPath))
-				err = os.Remove(bloomPath)
-				log.Check(err, "removing old junkfilter bloom filter file", slog.String("path", bloomPath))
-
-				// Open junk filter, this creates new files.
-				jf, _, err := acc.OpenJunkFilter(ctx, log)
-				xctl.xcheck(err, "open new junk filter")
-				defer func() {
-					if jf == nil {
-						return
-					}
-					err := jf.CloseDiscard()
-					log.Check(err, "closing junk filter during cleanup")
-				}()
-
-				// Read through messages with either junk or nonjunk flag set, and train them.
-				var total, trained int
-				err = acc.DB.Write(ctx, func(tx *bstore.Tx) error {
-					q := bstore.QueryTx[store.Message](tx)
-					q.FilterEqual("Expunged", false)
-					return q.ForEach(func(m store.Message) error {
-						total++
-						if m.Junk == m.Notjunk {
-							return nil
-						}
-						ok, err := acc.TrainMessage(ctx, log, jf, m.Notjunk, m)
-						if ok {
-							trained++
-						}
-						if m.TrainedJunk == nil || *m.TrainedJunk != m.Junk {
-							m.TrainedJunk = &m.Junk
-							if err := tx.Update(&m); err != nil {
-								return fmt.Errorf("marking message as trained: %v", err)
-							}
-						}
-						return err
-					})
-				})
-				xctl.xcheck(err, "training messages")
-				log.Info("retrained messages", slog.Int("total", total), slog.Int("trained", trained))
-
-				// Close junk filter, marking success.
-				err = jf.Close()
-				jf = nil
-				xctl.xcheck(err, "closing junk filter")
-			})
+			_, _, err = acc.RetrainJunkFilter(ctx, log)
+			xctl.xcheck(err, "retraining junk filter")
 		}
 
 		if account == "" {
@@ -1625,7 +2008,7 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 							n++
 
 							p := acc.MessagePath(m.ID)
-							st, err := os.Stat(p)
+							filesize, _, err := store.MsgFileSize(p, m.Compressed)
 							if err != nil {
 								mb := store.Mailbox{ID: m.MailboxID}
 								if xerr := tx.Get(&mb); xerr != nil {
@@ -1634,7 +2017,6 @@ func servectlcmd(ctx context.Context, xctl *ctl, cid int64, shutdown func()) {
 								fmt.Fprintf(xw, "checking file %s for message %d in mailbox %q (id %d): %v (continuing)\n", p, m.ID, mb.Name, mb.ID, err)
 								return nil
 							}
-							filesize := st.Size()
 							correctSize := int64(len(m.MsgPrefix)) + filesize
 							if m.Size == correctSize {
 								return nil
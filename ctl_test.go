@@ -14,17 +14,22 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mjl-/mox/admin"
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dmarcdb"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/imapclient"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/mtastsdb"
+	"github.com/mjl-/mox/postmasterdb"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/tlsrptdb"
@@ -192,6 +197,14 @@ func TestCtl(t *testing.T) {
 	testctl(func(xctl *ctl) {
 		ctlcmdQueueHoldrulesRemove(xctl, 2)
 	})
+
+	// "queueholdrulesresume"
+	testctl(func(xctl *ctl) {
+		ctlcmdQueueHoldrulesAdd(xctl, "mjl", "", "")
+	})
+	testctl(func(xctl *ctl) {
+		ctlcmdQueueHoldrulesResume(xctl, 3)
+	})
 	testctl(func(xctl *ctl) {
 		ctlcmdQueueHoldrulesList(xctl)
 	})
@@ -232,6 +245,21 @@ func TestCtl(t *testing.T) {
 		ctlcmdQueueRetiredPrint(xctl, "1")
 	})
 
+	// "queuedeadlist"
+	testctl(func(xctl *ctl) {
+		ctlcmdQueueDeadList(xctl, queue.DeadFilter{})
+	})
+
+	// "queuedeadrequeue"
+	testctl(func(xctl *ctl) {
+		ctlcmdQueueDeadCount(xctl, "queuedeadrequeue", queue.DeadFilter{})
+	})
+
+	// "queuedeadremove"
+	testctl(func(xctl *ctl) {
+		ctlcmdQueueDeadCount(xctl, "queuedeadremove", queue.DeadFilter{})
+	})
+
 	// "queuehooklist"
 	testctl(func(xctl *ctl) {
 		ctlcmdQueueHookList(xctl, queue.HookFilter{}, queue.HookSort{})
@@ -282,6 +310,17 @@ func TestCtl(t *testing.T) {
 		ctlcmdConfigAccountAdd(xctl, "mjl2", "mjl2@mox2.example")
 	})
 
+	// "accountimport"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAccountImport(xctl, []admin.AccountImport{{Name: "mjl4", Address: "mjl4@mox2.example", Addresses: []string{"mjl5@mox2.example"}}}, true, false)
+	})
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAccountImport(xctl, []admin.AccountImport{{Name: "mjl4", Address: "mjl4@mox2.example", Password: "testtest123"}}, false, true)
+	})
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAccountRemove(xctl, "mjl4")
+	})
+
 	// "addressadd"
 	testctl(func(xctl *ctl) {
 		ctlcmdConfigAddressAdd(xctl, "mjl3@mox2.example", "mjl2")
@@ -320,6 +359,34 @@ func TestCtl(t *testing.T) {
 		ctlcmdConfigAccountRemove(xctl, "mjl2")
 	})
 
+	// "adminuseradd"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAdminuserAdd(xctl, "dom", "domainadmin", []string{"mox.example"}, "test1234")
+	})
+
+	// "adminuserupdate"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAdminuserUpdate(xctl, "dom", "auditor", nil)
+	})
+
+	// "adminusersetpassword"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAdminuserSetpassword(xctl, "dom", "test4321")
+	})
+
+	// "adminuserlist"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAdminuserList(xctl)
+	})
+	if au, ok := mox.Conf.AdminUser("dom"); !ok || au.Role != "auditor" {
+		t.Fatalf("got admin user %v %v, expected auditor", au, ok)
+	}
+
+	// "adminuserrm"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigAdminuserRemove(xctl, "dom")
+	})
+
 	// "domaindisabled"
 	testctl(func(xctl *ctl) {
 		ctlcmdConfigDomainDisabled(xctl, dns.Domain{ASCII: "mox2.example"}, true)
@@ -418,9 +485,59 @@ func TestCtl(t *testing.T) {
 		ctlcmdSetLoglevels(xctl, "smtpserver", "debug")
 	})
 
+	// Mutating commands are recorded in the audit log, with the account but without
+	// other parameters.
+	auditEvents, err := store.AuditList(ctxbg, store.AuditFilter{Kind: store.AuditPassword, Actor: "(ctl)"}, 0)
+	tcheck(t, err, "list audit events")
+	if len(auditEvents) == 0 || auditEvents[len(auditEvents)-1].Action != "setaccountpassword" || auditEvents[len(auditEvents)-1].Account != "mjl" || auditEvents[len(auditEvents)-1].Result != "ok" {
+		t.Fatalf("got audit events %v, expected setaccountpassword for mjl", auditEvents)
+	}
+
+	// "tlscertsreload"
+	testctl(func(xctl *ctl) {
+		ctlcmdConfigTLSCertsReload(xctl)
+	})
+
+	// "auditlogexport"
+	testctl(func(xctl *ctl) {
+		ctlcmdAuditlogExport(xctl, "json", store.AuditFilter{})
+	})
+	testctl(func(xctl *ctl) {
+		ctlcmdAuditlogExport(xctl, "syslog", store.AuditFilter{Kind: store.AuditConfig})
+	})
+
+	// "junkimport"
+	testctl(func(xctl *ctl) {
+		ctlcmdJunkImport(xctl, "mjl", strings.NewReader(`{"Version":1,"SenderList":[{"Pattern":"Spammer@example.org","Action":"reject"}]}`))
+	})
+
+	// "junkexport"
+	testctl(func(xctl *ctl) {
+		ctlcmdJunkExport(xctl, "mjl")
+	})
+
+	// "provisioningtokenadd"
+	testctl(func(xctl *ctl) {
+		ctlcmdProvisioningTokenAdd(xctl, "panel")
+	})
+
+	// "provisioningtokenlist"
+	testctl(func(xctl *ctl) {
+		ctlcmdProvisioningTokenList(xctl)
+	})
+
+	// "provisioningtokenrm"
+	testctl(func(xctl *ctl) {
+		ctlcmdProvisioningTokenRemove(xctl, "panel")
+	})
+	if tokens, err := store.ProvisioningTokenList(ctxbg); err != nil || len(tokens) != 0 {
+		t.Fatalf("got tokens %v, err %v, expected none", tokens, err)
+	}
+
 	// Export data, import it again
-	xcmdExport(true, false, []string{filepath.FromSlash("testdata/ctl/data/tmp/export/mbox/"), filepath.FromSlash("testdata/ctl/data/accounts/mjl")}, &cmd{log: pkglog})
-	xcmdExport(false, false, []string{filepath.FromSlash("testdata/ctl/data/tmp/export/maildir/"), filepath.FromSlash("testdata/ctl/data/accounts/mjl")}, &cmd{log: pkglog})
+	xcmdExport(store.ExportMbox, false, []string{filepath.FromSlash("testdata/ctl/data/tmp/export/mbox/"), filepath.FromSlash("testdata/ctl/data/accounts/mjl")}, &cmd{log: pkglog})
+	xcmdExport(store.ExportMaildir, false, []string{filepath.FromSlash("testdata/ctl/data/tmp/export/maildir/"), filepath.FromSlash("testdata/ctl/data/accounts/mjl")}, &cmd{log: pkglog})
+	xcmdExport(store.ExportMaildirPP, false, []string{filepath.FromSlash("testdata/ctl/data/tmp/export/maildirpp/"), filepath.FromSlash("testdata/ctl/data/accounts/mjl")}, &cmd{log: pkglog})
 	testctl(func(xctl *ctl) {
 		ctlcmdImport(xctl, true, "mjl", "inbox", filepath.FromSlash("testdata/ctl/data/tmp/export/mbox/Inbox.mbox"))
 	})
@@ -513,6 +630,15 @@ func TestCtl(t *testing.T) {
 	err = tlsrptdb.Init()
 	tcheck(t, err, "tlsrptdb init")
 	defer tlsrptdb.Close()
+	err = postmasterdb.Init()
+	tcheck(t, err, "postmasterdb init")
+	defer postmasterdb.Close()
+	err = reputationdb.Init()
+	tcheck(t, err, "reputationdb init")
+	defer reputationdb.Close()
+	err = mlist.Init()
+	tcheck(t, err, "mlist init")
+	defer mlist.Close()
 	testctl(func(xctl *ctl) {
 		os.RemoveAll("testdata/ctl/data/tmp/backup")
 		err := os.WriteFile("testdata/ctl/data/receivedid.key", make([]byte, 16), 0600)
@@ -0,0 +1,95 @@
+package dav
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webauth"
+)
+
+// Authenticate verifies the HTTP basic authentication credentials and returns
+// the opened account, which the caller must close. Protocol, e.g. "caldav", is
+// used for the login attempt, the authentication realm and metrics. If
+// isForwarded is set, the remote IP for rate limiting authentication failures is
+// taken from the X-Forwarded-For header. If nil is returned, a response has been
+// written.
+func Authenticate(log mlog.Log, w http.ResponseWriter, r *http.Request, protocol string, isForwarded bool) *store.Account {
+	email, password, aok := r.BasicAuth()
+	if !aok {
+		log.Debug("missing http basic authentication credentials")
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+protocol+`"`)
+		http.Error(w, "401 - unauthorized - use http basic auth with email address as username", http.StatusUnauthorized)
+		return nil
+	}
+	log = log.With(slog.String("username", email))
+
+	t0 := time.Now()
+
+	// If remote IP/network resulted in too many authentication failures, refuse to serve.
+	remoteIP := webauth.RemoteIP(log, isForwarded, r)
+	if remoteIP == nil {
+		log.Debug("cannot find remote ip for rate limiter")
+		http.Error(w, "500 - internal server error - cannot find remote ip", http.StatusInternalServerError)
+		return nil
+	}
+	if !mox.LimiterFailedAuth.CanAdd(remoteIP, t0, 1) {
+		metrics.AuthenticationRatelimitedInc(protocol)
+		log.Debug("refusing connection due to many auth failures", slog.Any("remoteip", remoteIP))
+		http.Error(w, "429 - too many auth attempts", http.StatusTooManyRequests)
+		return nil
+	}
+
+	la := store.LoginAttempt{
+		RemoteIP:     remoteIP.String(),
+		TLS:          store.LoginAttemptTLS(r.TLS),
+		Protocol:     protocol,
+		AuthMech:     "httpbasic",
+		UserAgent:    r.UserAgent(),
+		LoginAddress: email,
+		Result:       store.AuthError,
+	}
+	defer func() {
+		store.LoginAttemptAdd(context.Background(), log, la)
+	}()
+
+	acc, accName, err := store.OpenEmailAuth(log, email, password, protocol, remoteIP, true)
+	la.AccountName = accName
+	if err != nil {
+		mox.LimiterFailedAuth.Add(remoteIP, t0, 1)
+		if errors.Is(err, mox.ErrDomainNotFound) || errors.Is(err, mox.ErrAddressNotFound) || errors.Is(err, store.ErrUnknownCredentials) || errors.Is(err, store.ErrLoginDisabled) || errors.Is(err, store.ErrLoginLocked) || errors.Is(err, store.ErrPasswordExpired) || errors.Is(err, store.ErrAppPassword) {
+			log.Debug("bad http basic authentication credentials")
+			la.Result = store.AuthBadCredentials
+			msg := "use http basic auth with email address as username"
+			if errors.Is(err, store.ErrLoginDisabled) {
+				la.Result = store.AuthLoginDisabled
+				msg = "login is disabled for this account"
+			} else if errors.Is(err, store.ErrLoginLocked) {
+				la.Result = store.AuthLoginLocked
+				msg = err.Error()
+			} else if errors.Is(err, store.ErrPasswordExpired) {
+				la.Result = store.AuthPasswordExpired
+				msg = err.Error()
+			} else if errors.Is(err, store.ErrAppPassword) {
+				la.Result = store.AuthAppPasswordRequired
+				msg = err.Error()
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="`+protocol+`"`)
+			http.Error(w, "401 - unauthorized - "+msg, http.StatusUnauthorized)
+			return nil
+		}
+		log.Errorx("verifying credentials", err)
+		http.Error(w, "500 - internal server error - error verifying credentials", http.StatusInternalServerError)
+		return nil
+	}
+	la.AccountName = acc.Name
+	la.Result = store.AuthSuccess
+	mox.LimiterFailedAuth.Reset(remoteIP, t0)
+	return acc
+}
@@ -0,0 +1,298 @@
+// Package dav has WebDAV helpers shared by the CalDAV and CardDAV servers:
+// parsing of XML requests, writing multistatus responses, authentication and
+// conditional requests.
+//
+// See RFC 4918 for WebDAV.
+package dav
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// XML namespaces, with the prefixes used in responses.
+const (
+	NSDAV     = "DAV:"
+	NSCalDAV  = "urn:ietf:params:xml:ns:caldav"
+	NSCardDAV = "urn:ietf:params:xml:ns:carddav"
+	NSCS      = "http://calendarserver.org/ns/"
+	NSAppleIC = "http://apple.com/ns/ical/"
+)
+
+var nsPrefixes = map[string]string{
+	NSDAV:     "d",
+	NSCalDAV:  "c",
+	NSCardDAV: "card",
+	NSCS:      "cs",
+	NSAppleIC: "ic",
+}
+
+// DAVName returns a name in the DAV namespace.
+func DAVName(local string) xml.Name {
+	return xml.Name{Space: NSDAV, Local: local}
+}
+
+// Element is any XML element in a request, with its text content.
+type Element struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// PropList is a list of properties, e.g. in a prop element.
+type PropList struct {
+	Elements []Element `xml:",any"`
+}
+
+// Names returns the names of the properties, nil for a nil list.
+func (l *PropList) Names() []xml.Name {
+	if l == nil {
+		return nil
+	}
+	var names []xml.Name
+	for _, e := range l.Elements {
+		names = append(names, e.XMLName)
+	}
+	return names
+}
+
+// PropfindRequest is the body of a PROPFIND request.
+type PropfindRequest struct {
+	XMLName  xml.Name  `xml:"DAV: propfind"`
+	AllProp  *struct{} `xml:"DAV: allprop"`
+	PropName *struct{} `xml:"DAV: propname"`
+	Prop     *PropList `xml:"DAV: prop"`
+}
+
+type PropSet struct {
+	Prop PropList `xml:"DAV: prop"`
+}
+
+// PropertyUpdate is the body of a PROPPATCH request. Also used for MKCALENDAR
+// and extended MKCOL requests.
+type PropertyUpdate struct {
+	XMLName xml.Name
+	Set     []PropSet `xml:"DAV: set"`
+	Remove  []PropSet `xml:"DAV: remove"`
+}
+
+// Maximum size of XML request bodies.
+const maxXMLSize = 1024 * 1024
+
+// ReadXML parses the XML request body into v. An empty body is not an error, v
+// is left untouched.
+func ReadXML(r *http.Request, v any) error {
+	buf, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxXMLSize))
+	if err != nil {
+		return fmt.Errorf("reading request body: %w", err)
+	}
+	if strings.TrimSpace(string(buf)) == "" {
+		return nil
+	}
+	if err := xml.Unmarshal(buf, v); err != nil {
+		return fmt.Errorf("parsing xml request body: %w", err)
+	}
+	return nil
+}
+
+// Escape escapes s for use as XML text.
+func Escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// Href returns an href element for p.
+func Href(p string) string {
+	return "<d:href>" + Escape(p) + "</d:href>"
+}
+
+// xmlName returns the tag name for n, with the xmlns attribute for unknown
+// namespaces. The closing tag is the tag name up to the first space.
+func xmlName(n xml.Name) string {
+	if p, ok := nsPrefixes[n.Space]; ok {
+		return p + ":" + n.Local
+	}
+	return fmt.Sprintf(`x:%s xmlns:x="%s"`, n.Local, Escape(n.Space))
+}
+
+// xmlElem returns an element with name n and inner XML.
+func xmlElem(n xml.Name, inner string) string {
+	tag := xmlName(n)
+	if inner == "" {
+		return "<" + tag + "/>"
+	}
+	return "<" + tag + ">" + inner + "</" + strings.SplitN(tag, " ", 2)[0] + ">"
+}
+
+// Prop is a property with its value as inner XML.
+type Prop struct {
+	Name  xml.Name
+	Value string
+}
+
+// Response is a response element in a multistatus, with properties grouped by
+// status.
+type Response struct {
+	Href   string
+	Status int               // For responses without properties, e.g. unknown hrefs in multiget.
+	Props  map[int][]Prop    // By HTTP status code.
+	Error  string            // Inner XML of error element, e.g. for preconditions.
+	order  []int             // Status codes in order of addition.
+	names  map[xml.Name]bool // Properties already added.
+}
+
+// Add adds a property with a status. Properties already present are ignored.
+func (r *Response) Add(status int, p Prop) {
+	if r.Props == nil {
+		r.Props = map[int][]Prop{}
+		r.names = map[xml.Name]bool{}
+	}
+	if r.names[p.Name] {
+		return
+	}
+	r.names[p.Name] = true
+	if _, ok := r.Props[status]; !ok {
+		r.order = append(r.order, status)
+	}
+	r.Props[status] = append(r.Props[status], p)
+}
+
+// FailOK changes properties with status OK to status failed dependency, for
+// PROPPATCH requests that are not applied because of other failed changes.
+func (r *Response) FailOK() {
+	ok := r.Props[http.StatusOK]
+	if len(ok) == 0 {
+		return
+	}
+	delete(r.Props, http.StatusOK)
+	r.order = slices.DeleteFunc(r.order, func(status int) bool { return status == http.StatusOK })
+	for _, p := range ok {
+		r.names[p.Name] = false
+		r.Add(http.StatusFailedDependency, p)
+	}
+}
+
+func (r *Response) xml() string {
+	var b strings.Builder
+	b.WriteString("<d:response>" + Href(r.Href))
+	if r.Status != 0 {
+		fmt.Fprintf(&b, "<d:status>HTTP/1.1 %d %s</d:status>", r.Status, http.StatusText(r.Status))
+	}
+	for _, status := range r.order {
+		b.WriteString("<d:propstat><d:prop>")
+		for _, p := range r.Props[status] {
+			b.WriteString(xmlElem(p.Name, p.Value))
+		}
+		fmt.Fprintf(&b, "</d:prop><d:status>HTTP/1.1 %d %s</d:status></d:propstat>", status, http.StatusText(status))
+	}
+	if r.Error != "" {
+		b.WriteString("<d:error>" + r.Error + "</d:error>")
+	}
+	b.WriteString("</d:response>")
+	return b.String()
+}
+
+// PropResponse returns a response for href with the requested properties from
+// the available properties. If names is nil, all properties are returned. With
+// nameOnly, only the property names are returned.
+func PropResponse(href string, available []Prop, names []xml.Name, nameOnly bool) *Response {
+	r := &Response{Href: href}
+	if names == nil {
+		for _, p := range available {
+			if nameOnly {
+				p.Value = ""
+			}
+			r.Add(http.StatusOK, p)
+		}
+		return r
+	}
+	for _, name := range names {
+		i := slices.IndexFunc(available, func(p Prop) bool { return p.Name == name })
+		if i < 0 {
+			r.Add(http.StatusNotFound, Prop{Name: name})
+		} else {
+			r.Add(http.StatusOK, available[i])
+		}
+	}
+	return r
+}
+
+// xmlns has the namespace declarations for the prefixes used in responses.
+const xmlns = `xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" xmlns:card="urn:ietf:params:xml:ns:carddav" xmlns:cs="http://calendarserver.org/ns/" xmlns:ic="http://apple.com/ns/ical/"`
+
+// WriteMultistatus writes a 207 multistatus response.
+func WriteMultistatus(w http.ResponseWriter, responses []*Response) {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n")
+	b.WriteString(`<d:multistatus ` + xmlns + `>`)
+	for _, r := range responses {
+		b.WriteString(r.xml())
+	}
+	b.WriteString("</d:multistatus>\n")
+	h := w.Header()
+	h.Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	w.Write([]byte(b.String()))
+}
+
+// WriteError writes an error response with a precondition element, e.g.
+// "<c:no-uid-conflict/>".
+func WriteError(w http.ResponseWriter, status int, precondition string) {
+	h := w.Header()
+	h.Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<?xml version="1.0" encoding="utf-8"?>`+"\n"+`<d:error %s>%s</d:error>`+"\n", xmlns, precondition)
+}
+
+// ValidName returns whether name can be used as name of a collection or resource.
+func ValidName(name string) bool {
+	return name != "" && name != "." && name != ".." && len(name) <= 255 && !strings.ContainsAny(name, "/\\\x00")
+}
+
+// matchETag returns whether an If-Match or If-None-Match header value matches the
+// etag of a resource. An empty etag means the resource doesn't exist.
+func matchETag(header, etag string) bool {
+	for _, s := range strings.Split(header, ",") {
+		s = strings.TrimSpace(s)
+		if s == "*" && etag != "" || etag != "" && strings.TrimPrefix(s, "W/") == `"`+etag+`"` {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckPreconditions returns whether the If-Match and If-None-Match headers allow
+// the request to proceed for a resource with etag, empty if it doesn't exist.
+func CheckPreconditions(r *http.Request, etag string) bool {
+	if h := r.Header.Get("If-Match"); h != "" && !matchETag(h, etag) {
+		return false
+	}
+	if h := r.Header.Get("If-None-Match"); h != "" && matchETag(h, etag) {
+		return false
+	}
+	return true
+}
+
+// StatusWriter keeps track of the response status code, for metrics.
+type StatusWriter struct {
+	http.ResponseWriter
+	Status int
+}
+
+func (w *StatusWriter) WriteHeader(status int) {
+	if w.Status == 0 {
+		w.Status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *StatusWriter) Write(buf []byte) (int, error) {
+	if w.Status == 0 {
+		w.Status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(buf)
+}
@@ -0,0 +1,476 @@
+package dkim
+
+// ARC, Authenticated Received Chain, RFC 8617.
+//
+// Intermediaries that modify messages, like mailing lists, break DKIM signatures
+// of the original author. With ARC, an intermediary records the authentication
+// results it saw in an ARC set: an ARC-Authentication-Results header, an
+// ARC-Message-Signature (a DKIM-like signature over the modified message), and an
+// ARC-Seal that signs the ARC sets of all intermediaries. Receivers can use a
+// validated chain to evaluate the authentication results from before the
+// modifications.
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+)
+
+// ARCStatus is the chain validation status of the ARC sets in a message, as
+// used in the "cv" tag of an ARC-Seal.
+type ARCStatus string
+
+const (
+	ARCNone ARCStatus = "none" // No ARC sets in message.
+	ARCPass ARCStatus = "pass" // All ARC sets are present and valid.
+	ARCFail ARCStatus = "fail" // Missing, invalid or failed ARC sets.
+)
+
+// Maximum number of ARC sets in a message.
+const arcMaxInstance = 50
+
+var errARCChain = errors.New("dkim: invalid arc chain")
+
+// arcSet holds the three headers for one ARC instance.
+type arcSet struct {
+	aar, ams, as *header
+}
+
+// arcTags parses the tag=value list of an ARC-Message-Signature or ARC-Seal
+// header value. Whitespace is removed from the values.
+func arcTags(value []byte) (map[string]string, error) {
+	tags := map[string]string{}
+	for _, t := range strings.Split(string(value), ";") {
+		t = strings.TrimSpace(t)
+		if t == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(t, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: tag without value", errARCChain)
+		}
+		k = strings.TrimSpace(k)
+		if _, ok := tags[k]; ok {
+			return nil, fmt.Errorf("%w: duplicate tag %q", errARCChain, k)
+		}
+		tags[k] = strings.Join(strings.Fields(v), "")
+	}
+	return tags, nil
+}
+
+// arcInstance returns the instance from the leading "i=" tag of an ARC header.
+func arcInstance(value []byte) (int, error) {
+	s, _, _ := strings.Cut(string(value), ";")
+	k, v, ok := strings.Cut(strings.TrimSpace(s), "=")
+	if !ok || strings.TrimSpace(k) != "i" {
+		return 0, fmt.Errorf("%w: missing instance", errARCChain)
+	}
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil || i < 1 || i > arcMaxInstance {
+		return 0, fmt.Errorf("%w: bad instance %q", errARCChain, v)
+	}
+	return i, nil
+}
+
+// arcSets returns the ARC sets from the message headers, ordered by instance. An
+// error is returned if the sets are not complete and consecutive.
+func arcSets(hdrs []header) ([]arcSet, error) {
+	sets := map[int]*arcSet{}
+	for i := range hdrs {
+		h := &hdrs[i]
+		if h.lkey != "arc-authentication-results" && h.lkey != "arc-message-signature" && h.lkey != "arc-seal" {
+			continue
+		}
+		inst, err := arcInstance(h.value)
+		if err != nil {
+			return nil, err
+		}
+		s := sets[inst]
+		if s == nil {
+			s = &arcSet{}
+			sets[inst] = s
+		}
+		var p **header
+		switch h.lkey {
+		case "arc-authentication-results":
+			p = &s.aar
+		case "arc-message-signature":
+			p = &s.ams
+		default:
+			p = &s.as
+		}
+		if *p != nil {
+			return nil, fmt.Errorf("%w: duplicate %s for instance %d", errARCChain, h.key, inst)
+		}
+		*p = h
+	}
+	l := make([]arcSet, len(sets))
+	for i := range l {
+		s := sets[i+1]
+		if s == nil || s.aar == nil || s.ams == nil || s.as == nil {
+			return nil, fmt.Errorf("%w: missing headers for instance %d", errARCChain, i+1)
+		}
+		l[i] = *s
+	}
+	return l, nil
+}
+
+// arcStripSignature returns the header with the value of the "b" tag removed, and
+// without trailing crlf, as used for calculating and verifying signatures.
+func arcStripSignature(raw []byte) string {
+	t := strings.Split(strings.TrimSuffix(string(raw), "\r\n"), ";")
+	for i, s := range t {
+		k, _, ok := strings.Cut(s, "=")
+		if ok && strings.TrimSpace(k) == "b" {
+			t[i] = k + "="
+		}
+	}
+	return strings.Join(t, ";")
+}
+
+// arcSealHash returns the hash over the ARC sets, for the ARC-Seal of the last
+// set. The last ARC-Seal must have its signature removed. If only is set, only
+// the last set is included.
+func arcSealHash(h crypto.Hash, sets []arcSet, lastSeal string, only bool) ([]byte, error) {
+	hh := h.New()
+	for i, s := range sets {
+		last := i == len(sets)-1
+		if only && !last {
+			continue
+		}
+		l := []string{string(s.aar.raw), string(s.ams.raw), string(s.as.raw)}
+		if last {
+			l[2] = lastSeal
+		}
+		for j, raw := range l {
+			ch, err := relaxedCanonicalHeaderWithoutCRLF(raw)
+			if err != nil {
+				return nil, err
+			}
+			hh.Write([]byte(ch))
+			if !last || j < 2 {
+				hh.Write([]byte("\r\n"))
+			}
+		}
+	}
+	return hh.Sum(nil), nil
+}
+
+// arcKey looks up the public key for domain and selector in the tags.
+func arcKey(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, tags map[string]string) (*Record, error) {
+	domain, err := dns.ParseDomain(tags["d"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: parsing domain: %v", errARCChain, err)
+	}
+	selector, err := dns.ParseDomain(tags["s"])
+	if err != nil {
+		return nil, fmt.Errorf("%w: parsing selector: %v", errARCChain, err)
+	}
+	_, record, _, _, err := Lookup(ctx, elog, resolver, selector, domain)
+	if err != nil {
+		return nil, err
+	}
+	if record.PublicKey == nil {
+		return nil, ErrKeyRevoked
+	}
+	return record, nil
+}
+
+// ARCVerify validates the ARC chain in the message, returning the chain
+// validation status and the number of ARC sets present. The ARC-Seal of each set
+// and the ARC-Message-Signature of the last set are verified.
+func ARCVerify(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, msg io.ReaderAt) (status ARCStatus, instances int, rerr error) {
+	log := mlog.New("dkim", elog)
+	defer func() {
+		log.Debugx("arc verify result", rerr, slog.Any("status", status), slog.Int("instances", instances))
+	}()
+
+	hdrs, bodyOffset, err := parseHeaders(bufio.NewReader(&moxio.AtReader{R: msg}))
+	if err != nil {
+		return ARCFail, 0, fmt.Errorf("%w: %s", ErrHeaderMalformed, err)
+	}
+	sets, err := arcSets(hdrs)
+	if err != nil {
+		return ARCFail, 0, err
+	} else if len(sets) == 0 {
+		return ARCNone, 0, nil
+	}
+	n := len(sets)
+
+	// The first seal must have cv=none, later seals cv=pass.
+	sealTags := make([]map[string]string, n)
+	for i, s := range sets {
+		tags, err := arcTags(s.as.value)
+		if err != nil {
+			return ARCFail, n, err
+		}
+		expect := ARCPass
+		if i == 0 {
+			expect = ARCNone
+		}
+		if ARCStatus(tags["cv"]) != expect {
+			return ARCFail, n, fmt.Errorf("%w: instance %d has cv=%s", errARCChain, i+1, tags["cv"])
+		}
+		sealTags[i] = tags
+	}
+
+	// Verify the message signature of the last set.
+	amsTags, err := arcTags(sets[n-1].ams.value)
+	if err != nil {
+		return ARCFail, n, err
+	}
+	sig := newSigWithDefaults()
+	sig.AlgorithmSign, sig.AlgorithmHash, _ = strings.Cut(amsTags["a"], "-")
+	sig.Domain, err = dns.ParseDomain(amsTags["d"])
+	if err != nil {
+		return ARCFail, n, fmt.Errorf("%w: parsing domain: %v", errARCChain, err)
+	}
+	sig.Selector, err = dns.ParseDomain(amsTags["s"])
+	if err != nil {
+		return ARCFail, n, fmt.Errorf("%w: parsing selector: %v", errARCChain, err)
+	}
+	if c := amsTags["c"]; c != "" {
+		sig.Canonicalization = c
+	}
+	for _, h := range strings.Split(amsTags["h"], ":") {
+		sig.SignedHeaders = append(sig.SignedHeaders, strings.TrimSpace(h))
+	}
+	if sig.BodyHash, err = base64.StdEncoding.DecodeString(amsTags["bh"]); err != nil {
+		return ARCFail, n, fmt.Errorf("%w: parsing body hash: %v", errARCChain, err)
+	}
+	if sig.Signature, err = base64.StdEncoding.DecodeString(amsTags["b"]); err != nil {
+		return ARCFail, n, fmt.Errorf("%w: parsing signature: %v", errARCChain, err)
+	}
+	hash, canonHeaderSimple, canonBodySimple, err := checkSignatureParams(ctx, log, sig)
+	if err != nil {
+		return ARCFail, n, err
+	}
+	record, err := arcKey(ctx, elog, resolver, amsTags)
+	if err != nil {
+		return ARCFail, n, err
+	}
+	br := bufio.NewReader(&moxio.AtReader{R: msg, Offset: int64(bodyOffset)})
+	verifySig := []byte(arcStripSignature(sets[n-1].ams.raw))
+	if st, err := verifySignatureRecord(record, sig, hash, canonHeaderSimple, canonBodySimple, hdrs, verifySig, br, true); st != StatusPass {
+		return ARCFail, n, fmt.Errorf("verifying arc-message-signature: %w", err)
+	}
+
+	// Verify the seals, from the most recent.
+	for i := n - 1; i >= 0; i-- {
+		tags := sealTags[i]
+		algSign, hashName, _ := strings.Cut(tags["a"], "-")
+		hash, ok := algHash(hashName)
+		if !ok {
+			return ARCFail, n, fmt.Errorf("%w: %q", ErrHashAlgorithmUnknown, hashName)
+		}
+		record, err := arcKey(ctx, elog, resolver, tags)
+		if err != nil {
+			return ARCFail, n, err
+		}
+		if !strings.EqualFold(record.Key, algSign) {
+			return ARCFail, n, fmt.Errorf("%w: dns record has algorithm %q, seal %q", ErrSigAlgMismatch, record.Key, algSign)
+		}
+		signature, err := base64.StdEncoding.DecodeString(tags["b"])
+		if err != nil {
+			return ARCFail, n, fmt.Errorf("%w: parsing seal signature: %v", errARCChain, err)
+		}
+		dh, err := arcSealHash(hash, sets[:i+1], arcStripSignature(sets[i].as.raw), false)
+		if err != nil {
+			return ARCFail, n, err
+		}
+		switch k := record.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if err := rsa.VerifyPKCS1v15(k, hash, dh, signature); err != nil {
+				return ARCFail, n, fmt.Errorf("%w: arc-seal instance %d: %s", ErrSigVerify, i+1, err)
+			}
+		case ed25519.PublicKey:
+			if !ed25519.Verify(k, dh, signature) {
+				return ARCFail, n, fmt.Errorf("%w: arc-seal instance %d", ErrSigVerify, i+1)
+			}
+		default:
+			return ARCFail, n, fmt.Errorf("%w: %q", ErrSigAlgorithmUnknown, record.Key)
+		}
+	}
+	return ARCPass, n, nil
+}
+
+// ARCSet is an ARC set in a message, with the domain of the intermediary that
+// sealed it, and the authentication results it recorded.
+type ARCSet struct {
+	Instance    int
+	Sealer      dns.Domain // From "d=" of the ARC-Seal.
+	AuthResults string     // Value of the ARC-Authentication-Results header without instance tag, unfolded, e.g. "mx.example.org; dmarc=pass header.from=example.com".
+}
+
+// ARCSets returns the ARC sets in the message, ordered by instance. The sets are
+// not verified, use ARCVerify to validate the chain.
+func ARCSets(msg io.ReaderAt) ([]ARCSet, error) {
+	hdrs, _, err := parseHeaders(bufio.NewReader(&moxio.AtReader{R: msg}))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrHeaderMalformed, err)
+	}
+	sets, err := arcSets(hdrs)
+	if err != nil {
+		return nil, err
+	}
+	l := make([]ARCSet, len(sets))
+	for i, s := range sets {
+		tags, err := arcTags(s.as.value)
+		if err != nil {
+			return nil, err
+		}
+		d, err := dns.ParseDomain(tags["d"])
+		if err != nil {
+			return nil, fmt.Errorf("%w: parsing domain of arc-seal instance %d: %v", errARCChain, i+1, err)
+		}
+		_, aar, _ := strings.Cut(string(s.aar.value), ";")
+		aar = strings.ReplaceAll(aar, "\r\n", "")
+		l[i] = ARCSet{i + 1, d, strings.TrimSpace(aar)}
+	}
+	return l, nil
+}
+
+// ARCSeal returns headers with a new ARC set for the message, to be prepended to
+// the message: an ARC-Seal, ARC-Message-Signature and ARC-Authentication-Results
+// header. The message, which can already contain earlier ARC sets, is signed with
+// the selector, with relaxed canonicalization. cv is the chain validation status
+// of the message as it was received, from ARCVerify. authResults is an
+// Authentication-Results header with the results from when the message was
+// received.
+func ARCSeal(ctx context.Context, elog *slog.Logger, domain dns.Domain, sel Selector, cv ARCStatus, authResults string, msg io.ReaderAt) (headers string, rerr error) {
+	log := mlog.New("dkim", elog)
+	defer func() {
+		log.Debugx("arc seal result", rerr, slog.Any("domain", domain), slog.Any("cv", cv))
+	}()
+
+	hdrs, bodyOffset, err := parseHeaders(bufio.NewReader(&moxio.AtReader{R: msg}))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", ErrHeaderMalformed, err)
+	}
+	sets, err := arcSets(hdrs)
+	if err != nil {
+		// We cannot seal over a broken chain, we'll start over in a way that cannot validate.
+		cv = ARCFail
+		sets = nil
+	}
+	instance := len(sets) + 1
+	if instance > arcMaxInstance {
+		return "", fmt.Errorf("%w: too many instances", errARCChain)
+	}
+
+	var algSign string
+	switch sel.PrivateKey.(type) {
+	case *rsa.PrivateKey:
+		algSign = "rsa"
+	case ed25519.PrivateKey:
+		algSign = "ed25519"
+	default:
+		return "", fmt.Errorf("internal error, unknown private key %T", sel.PrivateKey)
+	}
+	hash, ok := algHash(sel.Hash)
+	if !ok {
+		return "", fmt.Errorf("unrecognized hash algorithm %q", sel.Hash)
+	}
+	sign := func(dh []byte) ([]byte, error) {
+		if algSign == "ed25519" {
+			return sel.PrivateKey.Sign(cryptorand.Reader, dh, crypto.Hash(0))
+		}
+		return sel.PrivateKey.Sign(cryptorand.Reader, dh, hash)
+	}
+	now := timeNow().Unix()
+
+	// ARC-Authentication-Results, with the results from the Authentication-Results header.
+	ar := strings.TrimSuffix(authResults, "\r\n")
+	if _, v, ok := strings.Cut(ar, ":"); ok {
+		ar = strings.TrimLeft(v, " \t")
+	}
+	aar := fmt.Sprintf("ARC-Authentication-Results: i=%d; %s\r\n", instance, ar)
+
+	// ARC-Message-Signature, like a DKIM-Signature, but without ARC headers.
+	sig := newSigWithDefaults()
+	sig.AlgorithmSign = algSign
+	sig.AlgorithmHash = sel.Hash
+	sig.Domain = domain
+	sig.Selector = sel.Domain
+	sig.Canonicalization = "relaxed/relaxed"
+	sig.SignTime = now
+	for _, h := range sel.Headers {
+		if lh := strings.ToLower(h); !strings.HasPrefix(lh, "arc-") && lh != "dkim-signature" {
+			sig.SignedHeaders = append(sig.SignedHeaders, h)
+		}
+	}
+	br := bufio.NewReader(&moxio.AtReader{R: msg, Offset: int64(bodyOffset)})
+	if sig.BodyHash, err = bodyHash(hash.New(), false, br); err != nil {
+		return "", err
+	}
+	amsHeader := func() string {
+		w := &message.HeaderWriter{}
+		w.Addf("", "ARC-Message-Signature: i=%d;", instance)
+		w.Addf(" ", "a=%s;", sig.Algorithm())
+		w.Addf(" ", "c=%s;", sig.Canonicalization)
+		w.Addf(" ", "d=%s;", sig.Domain.ASCII)
+		w.Addf(" ", "s=%s;", sig.Selector.ASCII)
+		w.Addf(" ", "t=%d;", sig.SignTime)
+		w.Addf(" ", "h=%s;", strings.Join(sig.SignedHeaders, ":"))
+		w.Addf(" ", "bh=%s;", base64.StdEncoding.EncodeToString(sig.BodyHash))
+		w.Addf(" ", "b=")
+		if len(sig.Signature) > 0 {
+			w.AddWrap([]byte(base64.StdEncoding.EncodeToString(sig.Signature)), false)
+		}
+		w.Add("\r\n")
+		return w.String()
+	}
+	dh, err := dataHash(hash.New(), false, sig, hdrs, []byte(strings.TrimSuffix(amsHeader(), "\r\n")))
+	if err != nil {
+		return "", err
+	}
+	if sig.Signature, err = sign(dh); err != nil {
+		return "", fmt.Errorf("signing arc-message-signature: %v", err)
+	}
+	ams := amsHeader()
+
+	// ARC-Seal, over all ARC sets, including this new one. With a failed chain, only
+	// the new set is sealed.
+	var signature []byte
+	asHeader := func() string {
+		w := &message.HeaderWriter{}
+		w.Addf("", "ARC-Seal: i=%d;", instance)
+		w.Addf(" ", "a=%s;", sig.Algorithm())
+		w.Addf(" ", "t=%d;", now)
+		w.Addf(" ", "cv=%s;", cv)
+		w.Addf(" ", "d=%s;", domain.ASCII)
+		w.Addf(" ", "s=%s;", sel.Domain.ASCII)
+		w.Addf(" ", "b=")
+		if len(signature) > 0 {
+			w.AddWrap([]byte(base64.StdEncoding.EncodeToString(signature)), false)
+		}
+		w.Add("\r\n")
+		return w.String()
+	}
+	unsigned := asHeader()
+	sets = append(sets, arcSet{&header{raw: []byte(aar)}, &header{raw: []byte(ams)}, &header{raw: []byte(unsigned)}})
+	dh, err = arcSealHash(hash, sets, strings.TrimSuffix(unsigned, "\r\n"), cv == ARCFail)
+	if err != nil {
+		return "", err
+	}
+	if signature, err = sign(dh); err != nil {
+		return "", fmt.Errorf("signing arc-seal: %v", err)
+	}
+
+	return asHeader() + ams + aar, nil
+}
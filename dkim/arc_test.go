@@ -0,0 +1,99 @@
+package dkim
+
+import (
+	"context"
+	"crypto/ed25519"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/dns"
+)
+
+func TestARC(t *testing.T) {
+	msg := strings.ReplaceAll(`Message-ID: <test@other.example>
+Date: Fri, 10 Dec 2021 20:09:08 +0100
+To: list@mox.example
+From: Someone <someone@other.example>
+Subject: test
+
+test
+`, "\n", "\r\n")
+
+	rsaKey := getRSAKey(t)
+	ed25519Key := ed25519.NewKeyFromSeed(make([]byte, 32))
+
+	headers := strings.Split("From,To,Subject,Date,Message-ID,List-Id", ",")
+	selrsa := Selector{Hash: "sha256", PrivateKey: rsaKey, Headers: headers, Domain: dns.Domain{ASCII: "testrsa"}}
+	seled25519 := Selector{Hash: "sha256", PrivateKey: ed25519Key, Headers: headers, Domain: dns.Domain{ASCII: "tested25519"}}
+
+	makeRecord := func(k string, publicKey any) string {
+		tr := &Record{Version: "DKIM1", Key: k, PublicKey: publicKey}
+		txt, err := tr.Record()
+		if err != nil {
+			t.Fatalf("making dns txt record: %s", err)
+		}
+		return txt
+	}
+	resolver := dns.MockResolver{
+		TXT: map[string][]string{
+			"testrsa._domainkey.mox.example.":    {makeRecord("rsa", rsaKey.Public())},
+			"tested25519._domainkey.other.test.": {makeRecord("ed25519", ed25519Key.Public())},
+		},
+	}
+
+	ctx := context.Background()
+	const authResults = "Authentication-Results: mox.example;\r\n\tspf=pass smtp.mailfrom=other.example\r\n"
+
+	verify := func(m string, expStatus ARCStatus, expInstances int) {
+		t.Helper()
+		status, n, err := ARCVerify(ctx, pkglog.Logger, resolver, strings.NewReader(m))
+		if status != expStatus || n != expInstances {
+			t.Fatalf("arc verify: got status %s, instances %d, err %v, expected %s, %d", status, n, err, expStatus, expInstances)
+		}
+	}
+
+	verify(msg, ARCNone, 0)
+
+	// First set, after modifying the message.
+	msg1 := "List-Id: <list.mox.example>\r\n" + msg
+	arc1, err := ARCSeal(ctx, pkglog.Logger, dns.Domain{ASCII: "mox.example"}, selrsa, ARCNone, authResults, strings.NewReader(msg1))
+	if err != nil {
+		t.Fatalf("arc seal: %v", err)
+	}
+	if !strings.Contains(arc1, "ARC-Seal: i=1;") || !strings.Contains(arc1, "cv=none;") || !strings.Contains(arc1, "ARC-Authentication-Results: i=1; mox.example;") {
+		t.Fatalf("unexpected arc headers %q", arc1)
+	}
+	msg1 = arc1 + msg1
+	verify(msg1, ARCPass, 1)
+
+	// Second set by another intermediary, with another key type.
+	msg2 := "X-Other: modified\r\n" + msg1
+	arc2, err := ARCSeal(ctx, pkglog.Logger, dns.Domain{ASCII: "other.test"}, seled25519, ARCPass, authResults, strings.NewReader(msg2))
+	if err != nil {
+		t.Fatalf("arc seal: %v", err)
+	}
+	msg2 = arc2 + msg2
+	verify(msg2, ARCPass, 2)
+
+	sets, err := ARCSets(strings.NewReader(msg2))
+	if err != nil {
+		t.Fatalf("arc sets: %v", err)
+	}
+	exp := []ARCSet{
+		{1, dns.Domain{ASCII: "mox.example"}, "mox.example;\tspf=pass smtp.mailfrom=other.example"}, // Unfolded.
+		{2, dns.Domain{ASCII: "other.test"}, "mox.example;\tspf=pass smtp.mailfrom=other.example"},
+	}
+	if !reflect.DeepEqual(sets, exp) {
+		t.Fatalf("arc sets: got %#v, expected %#v", sets, exp)
+	}
+
+	// Modified body breaks the last message signature.
+	verify(strings.Replace(msg2, "\r\n\r\ntest", "\r\n\r\nchanged", 1), ARCFail, 2)
+
+	// Modified earlier set breaks the seal.
+	verify(strings.Replace(msg2, "spf=pass", "spf=fail", 1), ARCFail, 2)
+
+	// Missing set.
+	verify(arc2+msg, ARCFail, 0)
+}
@@ -0,0 +1,334 @@
+package dmarcdb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dmarcrpt"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+// Analytics summarizes the aggregate reports for a domain over a period.
+//
+// Message counts are as reported by the reporting organizations. A message
+// fails DMARC if neither DKIM nor SPF passed with alignment.
+type Analytics struct {
+	Domain  string // Empty for all domains.
+	Reports int
+	Total   int
+	Pass    int
+	Fail    int
+
+	DKIMAlignedPass int // DKIM pass with alignment, as evaluated for the DMARC policy.
+	SPFAlignedPass  int // SPF pass with alignment.
+	DKIMAuthPass    int // At least one DKIM signature passed, aligned or not.
+	SPFAuthPass     int // SPF passed, aligned or not.
+
+	Quarantine int // Disposition of messages.
+	Reject     int
+
+	Days           []AnalyticsDay      // Sorted by day, for trends.
+	Sources        []AnalyticsSource   // Most messages first.
+	FailingSources []AnalyticsSource   // Most failures first, only sources with failures.
+	Reporters      []AnalyticsReporter // Most messages first.
+}
+
+// AnalyticsDay holds message counts for reports with a period starting on a day.
+type AnalyticsDay struct {
+	Day      string // In UTC, "2006-01-02".
+	Total    int
+	Fail     int
+	DKIMFail int
+	SPFFail  int
+}
+
+// AnalyticsSource holds message counts for a sending IP.
+type AnalyticsSource struct {
+	IP       string
+	Total    int
+	Fail     int
+	DKIMFail int
+	SPFFail  int
+	Domains  []string // Domains from message From headers.
+}
+
+// AnalyticsReporter holds message counts for an organization sending reports.
+type AnalyticsReporter struct {
+	OrgName string
+	Reports int
+	Total   int
+	Fail    int
+}
+
+// Analyze gathers statistics for the reports overlapping period start/end for
+// domain, or all domains if empty. At most limit sources, failing sources and
+// reporters are returned, or all if limit is 0.
+func Analyze(ctx context.Context, start, end time.Time, domain string, limit int) (Analytics, error) {
+	reports, err := RecordsPeriodDomain(ctx, start, end, domain)
+	if err != nil {
+		return Analytics{}, err
+	}
+	return analyze(reports, domain, limit), nil
+}
+
+func analyze(reports []DomainFeedback, domain string, limit int) Analytics {
+	a := Analytics{Domain: domain, Reports: len(reports)}
+
+	days := map[string]*AnalyticsDay{}
+	sources := map[string]*AnalyticsSource{}
+	reporters := map[string]*AnalyticsReporter{}
+
+	for _, r := range reports {
+		day := time.Unix(r.ReportMetadata.DateRange.Begin, 0).UTC().Format("2006-01-02")
+		d := days[day]
+		if d == nil {
+			d = &AnalyticsDay{Day: day}
+			days[day] = d
+		}
+		rep := reporters[r.ReportMetadata.OrgName]
+		if rep == nil {
+			rep = &AnalyticsReporter{OrgName: r.ReportMetadata.OrgName}
+			reporters[r.ReportMetadata.OrgName] = rep
+		}
+		rep.Reports++
+
+		for _, record := range r.Records {
+			n := record.Row.Count
+			pe := record.Row.PolicyEvaluated
+			dkimPass := pe.DKIM == dmarcrpt.DMARCPass
+			spfPass := pe.SPF == dmarcrpt.DMARCPass
+			fail := !dkimPass && !spfPass
+
+			src := sources[record.Row.SourceIP]
+			if src == nil {
+				src = &AnalyticsSource{IP: record.Row.SourceIP}
+				sources[record.Row.SourceIP] = src
+			}
+			if hf := strings.ToLower(record.Identifiers.HeaderFrom); hf != "" && !slices.Contains(src.Domains, hf) {
+				src.Domains = append(src.Domains, hf)
+			}
+
+			a.Total += n
+			d.Total += n
+			src.Total += n
+			rep.Total += n
+			if fail {
+				a.Fail += n
+				d.Fail += n
+				src.Fail += n
+				rep.Fail += n
+			} else {
+				a.Pass += n
+			}
+			if dkimPass {
+				a.DKIMAlignedPass += n
+			} else {
+				d.DKIMFail += n
+				src.DKIMFail += n
+			}
+			if spfPass {
+				a.SPFAlignedPass += n
+			} else {
+				d.SPFFail += n
+				src.SPFFail += n
+			}
+			if slices.ContainsFunc(record.AuthResults.DKIM, func(r dmarcrpt.DKIMAuthResult) bool { return r.Result == dmarcrpt.DKIMPass }) {
+				a.DKIMAuthPass += n
+			}
+			if slices.ContainsFunc(record.AuthResults.SPF, func(r dmarcrpt.SPFAuthResult) bool { return r.Result == dmarcrpt.SPFPass }) {
+				a.SPFAuthPass += n
+			}
+			switch pe.Disposition {
+			case dmarcrpt.DispositionQuarantine:
+				a.Quarantine += n
+			case dmarcrpt.DispositionReject:
+				a.Reject += n
+			}
+		}
+	}
+
+	for _, d := range days {
+		a.Days = append(a.Days, *d)
+	}
+	slices.SortFunc(a.Days, func(x, y AnalyticsDay) int { return strings.Compare(x.Day, y.Day) })
+
+	for _, src := range sources {
+		slices.Sort(src.Domains)
+		a.Sources = append(a.Sources, *src)
+		if src.Fail > 0 {
+			a.FailingSources = append(a.FailingSources, *src)
+		}
+	}
+	slices.SortFunc(a.Sources, func(x, y AnalyticsSource) int {
+		if x.Total != y.Total {
+			return y.Total - x.Total
+		}
+		return strings.Compare(x.IP, y.IP)
+	})
+	slices.SortFunc(a.FailingSources, func(x, y AnalyticsSource) int {
+		if x.Fail != y.Fail {
+			return y.Fail - x.Fail
+		}
+		return strings.Compare(x.IP, y.IP)
+	})
+
+	for _, rep := range reporters {
+		a.Reporters = append(a.Reporters, *rep)
+	}
+	slices.SortFunc(a.Reporters, func(x, y AnalyticsReporter) int {
+		if x.Total != y.Total {
+			return y.Total - x.Total
+		}
+		return strings.Compare(x.OrgName, y.OrgName)
+	})
+
+	if limit > 0 {
+		a.Sources = a.Sources[:min(limit, len(a.Sources))]
+		a.FailingSources = a.FailingSources[:min(limit, len(a.FailingSources))]
+		a.Reporters = a.Reporters[:min(limit, len(a.Reporters))]
+	}
+	return a
+}
+
+// FailureAlert records when an alert about a spike in DMARC failures was last
+// sent for a domain.
+type FailureAlert struct {
+	Domain string // Policy domain, as in DomainFeedback.
+	Sent   time.Time
+}
+
+const (
+	// Period of recent reports compared against the preceding baseline period.
+	alertRecent   = 2 * 24 * time.Hour
+	alertBaseline = 7 * 24 * time.Hour
+	// Minimum number of messages in the recent period before we alert, to prevent
+	// alerts for a few messages.
+	alertMinMessages = 10
+)
+
+// CheckFailureAlert checks whether the failure rate in recent reports for
+// domain has spiked, and if so, delivers an alert message to the mailbox
+// configured for DMARC reports of the domain. Alerts are only sent for domains
+// with a FailureAlertPercentage configured, and at most once a day.
+//
+// The failure rate has spiked if it is at least the configured percentage and
+// at least twice the rate of the preceding week.
+func CheckFailureAlert(ctx context.Context, log mlog.Log, domain dns.Domain, now time.Time) error {
+	dc, ok := mox.Conf.Domain(domain)
+	if !ok || dc.DMARC == nil || dc.DMARC.FailureAlertPercentage <= 0 {
+		return nil
+	}
+	name := domain.Name()
+
+	fa := FailureAlert{Domain: name}
+	err := ReportsDB.Get(ctx, &fa)
+	if err != nil && err != bstore.ErrAbsent {
+		return fmt.Errorf("get last failure alert: %v", err)
+	} else if err == nil && now.Sub(fa.Sent) < 24*time.Hour {
+		return nil
+	}
+	exists := err == nil
+
+	recent, err := Analyze(ctx, now.Add(-alertRecent), now, name, 5)
+	if err != nil {
+		return fmt.Errorf("analyzing recent reports: %v", err)
+	}
+	if recent.Total < alertMinMessages || recent.Fail*100 < dc.DMARC.FailureAlertPercentage*recent.Total {
+		return nil
+	}
+	baseline, err := Analyze(ctx, now.Add(-alertRecent-alertBaseline), now.Add(-alertRecent), name, 0)
+	if err != nil {
+		return fmt.Errorf("analyzing baseline reports: %v", err)
+	}
+	// Compare recent.Fail/recent.Total >= 2*baseline.Fail/baseline.Total.
+	if baseline.Total > 0 && recent.Fail*baseline.Total < 2*baseline.Fail*recent.Total {
+		return nil
+	}
+
+	log.Info("dmarc failure rate spiked, sending alert",
+		slog.String("domain", name),
+		slog.Int("total", recent.Total),
+		slog.Int("fail", recent.Fail),
+		slog.Int("baselinetotal", baseline.Total),
+		slog.Int("baselinefail", baseline.Fail))
+
+	if err := deliverFailureAlert(log, domain, dc.DMARC.Account, dc.DMARC.Mailbox, recent, baseline, now); err != nil {
+		return err
+	}
+
+	fa.Sent = now
+	if exists {
+		err = ReportsDB.Update(ctx, &fa)
+	} else {
+		err = ReportsDB.Insert(ctx, &fa)
+	}
+	if err != nil {
+		return fmt.Errorf("storing failure alert: %v", err)
+	}
+	return nil
+}
+
+// deliverFailureAlert delivers a message about a failure spike to the account
+// and mailbox that receive DMARC reports.
+func deliverFailureAlert(log mlog.Log, domain dns.Domain, accountName, mailbox string, recent, baseline Analytics, now time.Time) error {
+	acc, err := store.OpenAccount(log, accountName, false)
+	if err != nil {
+		return fmt.Errorf("open account for failure alert: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	f, err := store.CreateMessageTemp(log, "dmarc-alert")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "dmarc failure alert")
+
+	pct := func(n, total int) string {
+		if total == 0 {
+			return "-"
+		}
+		return fmt.Sprintf("%.1f%%", float64(n)*100/float64(total))
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi!\r\n\r\nDMARC aggregate reports for %s show a spike in messages failing DMARC.\r\n\r\n", domain)
+	fmt.Fprintf(&b, "Last %d days: %d of %d messages failed (%s).\r\n", int(alertRecent/(24*time.Hour)), recent.Fail, recent.Total, pct(recent.Fail, recent.Total))
+	fmt.Fprintf(&b, "Preceding %d days: %d of %d messages failed (%s).\r\n", int(alertBaseline/(24*time.Hour)), baseline.Fail, baseline.Total, pct(baseline.Fail, baseline.Total))
+	if len(recent.FailingSources) > 0 {
+		fmt.Fprintf(&b, "\r\nSource IPs with most failures:\r\n\r\n")
+		for _, src := range recent.FailingSources {
+			fmt.Fprintf(&b, "- %s: %d of %d failed, From domains %s\r\n", src.IP, src.Fail, src.Total, strings.Join(src.Domains, ", "))
+		}
+	}
+	fmt.Fprintf(&b, "\r\nMessages may be spoofed, or a legitimate service is sending without proper DKIM/SPF configuration. See the DMARC reports in the admin web interface for details.\r\n\r\nCheers,\r\nmox\r\n")
+
+	n, err := fmt.Fprintf(f, "Date: %s\r\nSubject: DMARC failure spike for %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8-bit\r\n\r\n%s", now.Format(message.RFC5322Z), domain, b.String())
+	if err != nil {
+		return fmt.Errorf("writing failure alert: %v", err)
+	}
+
+	m := store.Message{
+		Received: now,
+		Flags:    store.Flags{Flagged: true},
+		Size:     int64(n),
+	}
+	acc.WithWLock(func() {
+		err = acc.DeliverMailbox(log, mailbox, &m, f)
+	})
+	if err != nil {
+		return fmt.Errorf("delivering failure alert: %v", err)
+	}
+	return nil
+}
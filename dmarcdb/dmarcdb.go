@@ -6,8 +6,9 @@
 // keeps track of the evaluations it does for incoming messages and sends reports
 // to mail servers that request reports.
 //
-// Only aggregate reports are stored and sent. Failure reports about individual
-// messages are not implemented.
+// Failure reports about individual messages that failed DMARC are stored too.
+// If enabled in the configuration, mox sends failure reports for messages that
+// fail DMARC to domains that request them.
 package dmarcdb
 
 import (
@@ -0,0 +1,475 @@
+package dmarcdb
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net"
+	"net/textproto"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dmarc"
+	"github.com/mjl-/mox/dmarcrpt"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/publicsuffix"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/spf"
+	"github.com/mjl-/mox/store"
+)
+
+// DomainFailureReport is a single incoming failure report stored in the database.
+type DomainFailureReport struct {
+	ID       int64
+	Received time.Time `bstore:"default now,index"`
+	// Domain the report is about, from Reported-Domain.
+	Domain string `bstore:"index"`
+	// Domain in From-header of the report message.
+	FromDomain string `bstore:"index"`
+	dmarcrpt.FailureReport
+}
+
+// AddFailureReport adds an incoming DMARC failure report to the database.
+//
+// fromDomain is the domain in the report message From header.
+func AddFailureReport(ctx context.Context, r *dmarcrpt.FailureReport, fromDomain dns.Domain) error {
+	d, err := dns.ParseDomain(r.ReportedDomain)
+	if err != nil {
+		return fmt.Errorf("parsing reported domain: %v", err)
+	}
+	fr := DomainFailureReport{Domain: d.Name(), FromDomain: fromDomain.Name(), FailureReport: *r}
+	return ReportsDB.Insert(ctx, &fr)
+}
+
+// FailureReportsPeriodDomain returns the failure reports received between start
+// and end, for the given domain, or all domains if empty. Most recent first.
+func FailureReportsPeriodDomain(ctx context.Context, start, end time.Time, domain string) ([]DomainFailureReport, error) {
+	q := bstore.QueryDB[DomainFailureReport](ctx, ReportsDB)
+	if domain != "" {
+		q.FilterNonzero(DomainFailureReport{Domain: domain})
+	}
+	q.FilterGreaterEqual("Received", start)
+	q.FilterLess("Received", end)
+	q.SortDesc("Received")
+	return q.List()
+}
+
+// FailureReportID returns the failure report for the ID.
+func FailureReportID(ctx context.Context, id int64) (DomainFailureReport, error) {
+	fr := DomainFailureReport{ID: id}
+	err := ReportsDB.Get(ctx, &fr)
+	return fr, err
+}
+
+// FailedMessage holds details about an incoming message that failed DMARC
+// evaluation, for sending a failure report.
+type FailedMessage struct {
+	Result      dmarc.Result // With non-nil Record.
+	DKIMResults []dkim.Result
+	SPFResult   spf.Status
+	SourceIP    net.IP
+	MailFrom    smtp.Path
+	RcptTo      smtp.Path
+	Arrival     time.Time
+	Delivered   bool   // Whether we accepted the message.
+	AuthResults string // Value of our Authentication-Results header.
+	Msg         io.ReaderAt
+}
+
+// Number of failure reports sent per policy domain for the current day, for
+// rate limiting.
+var failureReportCounts = struct {
+	sync.Mutex
+	day    string
+	counts map[string]int
+}{}
+
+// failureReportAllowed returns whether another failure report can be sent to
+// domain today, and if so, counts it.
+func failureReportAllowed(domain string, now time.Time, max int) bool {
+	failureReportCounts.Lock()
+	defer failureReportCounts.Unlock()
+	day := now.UTC().Format("20060102")
+	if failureReportCounts.day != day {
+		failureReportCounts.day = day
+		failureReportCounts.counts = map[string]int{}
+	}
+	if failureReportCounts.counts[domain] >= max {
+		return false
+	}
+	failureReportCounts.counts[domain]++
+	return true
+}
+
+// failureReportRequested returns whether the failure reporting options in the
+// DMARC record ask for a report about the message.
+func failureReportRequested(fm FailedMessage) bool {
+	r := fm.Result
+	for _, o := range r.Record.FailureReportingOptions {
+		switch o {
+		case "0":
+			// All mechanisms failed to produce an aligned pass.
+			if !r.AlignedDKIMPass && !r.AlignedSPFPass {
+				return true
+			}
+		case "1":
+			// Any mechanism failed to produce an aligned pass.
+			if !r.AlignedDKIMPass || !r.AlignedSPFPass {
+				return true
+			}
+		case "d":
+			// A DKIM signature failed verification, regardless of alignment.
+			if slices.ContainsFunc(fm.DKIMResults, func(dr dkim.Result) bool { return dr.Status == dkim.StatusFail }) {
+				return true
+			}
+		case "s":
+			// SPF failed, regardless of alignment.
+			if fm.SPFResult == spf.StatusFail {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SendFailureReport queues a DMARC failure report about a message to the
+// addresses in the "ruf" field of the DMARC record of the policy domain, if
+// failure reports are enabled in the configuration, the reporting options in the
+// record ask for a report and the daily limit for the domain hasn't been reached.
+func SendFailureReport(ctx context.Context, log mlog.Log, resolver dns.Resolver, fm FailedMessage) error {
+	conf := mox.Conf.Static.OutgoingDMARCFailureReports
+	if conf == nil || fm.Result.Record == nil || len(fm.Result.Record.FailureReportAddresses) == 0 || !failureReportRequested(fm) {
+		return nil
+	}
+	// We only support the ARF format, which is the only defined format.
+	if len(fm.Result.Record.ReportingFormat) > 0 && !slices.ContainsFunc(fm.Result.Record.ReportingFormat, func(s string) bool { return strings.EqualFold(s, "afrf") }) {
+		log.Debug("dmarc record does not accept afrf failure report format, not sending failure report")
+		return nil
+	}
+
+	dom := fm.Result.Domain
+	log = log.With(slog.Any("policydomain", dom))
+	max := conf.MaxPerDay
+	if max == 0 {
+		max = 10
+	}
+	if !failureReportAllowed(dom.Name(), time.Now(), max) {
+		log.Debug("daily limit for dmarc failure reports reached for domain, not sending")
+		return nil
+	}
+
+	recipients := failureRecipients(ctx, log, resolver, dom, fm.Result.Record)
+	if len(recipients) == 0 {
+		log.Debug("no failure reporting addresses that accept reports")
+		return nil
+	}
+
+	header, err := message.ReadHeaders(bufio.NewReader(&moxio.AtReader{R: fm.Msg}))
+	if err != nil {
+		return fmt.Errorf("reading header of message: %v", err)
+	}
+
+	msgf, err := store.CreateMessageTemp(log, "dmarcfailurereportout")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file for outgoing dmarc failure report: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, msgf, "message with generated dmarc failure report")
+
+	from := smtp.NewAddress("postmaster", mox.Conf.Static.HostnameDomain)
+	subject := fmt.Sprintf("DMARC failure report for %s", dom.ASCII)
+	var addrs []message.NameAddress
+	for _, rcpt := range recipients {
+		addrs = append(addrs, message.NameAddress{Address: rcpt.address})
+	}
+
+	msgPrefix, has8bit, smtputf8, messageID, err := composeFailureReport(ctx, log, msgf, from, addrs, subject, fm, string(header), conf.Redact)
+	if err != nil {
+		return fmt.Errorf("composing message with outgoing dmarc failure report: %v", err)
+	}
+	msgInfo, err := msgf.Stat()
+	if err != nil {
+		return fmt.Errorf("stat message with outgoing dmarc failure report: %v", err)
+	}
+	msgSize := int64(len(msgPrefix)) + msgInfo.Size()
+
+	for _, rcpt := range recipients {
+		q := bstore.QueryDB[SuppressAddress](ctx, EvalDB)
+		q.FilterNonzero(SuppressAddress{ReportingAddress: rcpt.address.Path().String()})
+		q.FilterGreater("Until", time.Now())
+		exists, err := q.Exists()
+		if err != nil {
+			return fmt.Errorf("querying suppress list: %v", err)
+		}
+		if exists {
+			log.Info("suppressing outgoing dmarc failure report", slog.Any("reportingaddress", rcpt.address))
+			continue
+		}
+		if rcpt.maxSize > 0 && msgSize > int64(rcpt.maxSize) {
+			continue
+		}
+
+		qm := queue.MakeMsg(from.Path(), rcpt.address.Path(), has8bit, smtputf8, msgSize, messageID, []byte(msgPrefix), nil, time.Now(), subject)
+		qm.MaxAttempts = 5
+		qm.IsDMARCReport = true
+		if err := queueAdd(ctx, log, mox.Conf.Static.Postmaster.Account, msgf, qm); err != nil {
+			log.Errorx("queueing message with dmarc failure report", err)
+			metricReportError.Inc()
+		} else {
+			log.Debug("dmarc failure report queued", slog.Any("recipient", rcpt.address))
+			metricReport.Inc()
+		}
+	}
+	return nil
+}
+
+// failureRecipients returns the addresses from the "ruf" field of the DMARC
+// record that accept reports. Addresses in another organizational domain must
+// opt in to receiving reports, and can specify replacement addresses, as for
+// aggregate reports.
+func failureRecipients(ctx context.Context, log mlog.Log, resolver dns.Resolver, dom dns.Domain, record *dmarc.Record) []recipient {
+	var recipients []recipient
+	orgDom := publicsuffix.Lookup(ctx, log.Logger, dom)
+	for _, uri := range record.FailureReportAddresses {
+		r, ok := parseRecipient(log, uri)
+		if !ok {
+			continue
+		}
+		if publicsuffix.Lookup(ctx, log.Logger, r.address.Domain) == orgDom {
+			recipients = append(recipients, r)
+			continue
+		}
+
+		accepts, status, records, _, _, err := dmarc.LookupExternalReportsAccepted(ctx, log.Logger, resolver, orgDom, r.address.Domain)
+		log.Debugx("checking if ruf address with different organization domain has opted into receiving dmarc reports", err,
+			slog.Any("destinationdomain", r.address.Domain),
+			slog.Bool("accepts", accepts),
+			slog.Any("status", status))
+		if !accepts {
+			continue
+		}
+		foundReplacement := false
+		for _, record := range records {
+			for _, exturi := range record.FailureReportAddresses {
+				extr, ok := parseRecipient(log, exturi)
+				if ok && extr.address.Domain == r.address.Domain {
+					foundReplacement = true
+					recipients = append(recipients, extr)
+				}
+			}
+		}
+		if !foundReplacement {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+// Key for redacting localparts, random for each run of mox.
+var redactKey = sync.OnceValue(func() []byte {
+	buf := make([]byte, 16)
+	if _, err := cryptorand.Read(buf); err != nil {
+		panic(fmt.Errorf("reading random bytes: %v", err))
+	}
+	return buf
+})
+
+// redactLocalpart returns a keyed hash of a localpart, so the same localpart
+// results in the same redacted localpart without revealing it.
+func redactLocalpart(lp string) string {
+	h := hmac.New(sha256.New, redactKey())
+	h.Write([]byte(strings.ToLower(lp)))
+	return "redacted-" + strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(h.Sum(nil)[:10]))
+}
+
+var addressRegexp = regexp.MustCompile(`[a-zA-Z0-9!#$%&'*+/=?^_{|}~.-]+@[a-zA-Z0-9.-]+`)
+
+// redactAddresses replaces the localparts of email addresses in s.
+func redactAddresses(s string) string {
+	return addressRegexp.ReplaceAllStringFunc(s, func(addr string) string {
+		i := strings.LastIndex(addr, "@")
+		return redactLocalpart(addr[:i]) + addr[i:]
+	})
+}
+
+// redactHeader replaces the localparts of addresses in header fields, except for
+// fields about the sender and message identification, which are of use to the
+// domain owner and aren't about our users.
+func redactHeader(header string) string {
+	keep := []string{"from", "sender", "reply-to", "return-path", "message-id", "in-reply-to", "references", "dkim-signature"}
+	var b strings.Builder
+	var redact bool
+	for _, line := range strings.SplitAfter(header, "\n") {
+		if line != "" && line[0] != ' ' && line[0] != '\t' {
+			k, _, _ := strings.Cut(line, ":")
+			redact = !slices.Contains(keep, strings.ToLower(strings.TrimSpace(k)))
+		}
+		if redact {
+			line = redactAddresses(line)
+		}
+		b.WriteString(line)
+	}
+	return b.String()
+}
+
+func composeFailureReport(ctx context.Context, log mlog.Log, mf *os.File, fromAddr smtp.Address, recipients []message.NameAddress, subject string, fm FailedMessage, header string, redact bool) (msgPrefix string, has8bit, smtputf8 bool, messageID string, rerr error) {
+	// We only use smtputf8 if we have to, with a utf-8 localpart. For IDNA, we use ASCII domains.
+	smtputf8 = fromAddr.Localpart.IsInternational()
+	for _, r := range recipients {
+		if smtputf8 {
+			smtputf8 = r.Address.Localpart.IsInternational()
+			break
+		}
+	}
+	xc := message.NewComposer(mf, 100*1024*1024, smtputf8)
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(error); ok && errors.Is(err, message.ErrCompose) {
+			rerr = err
+			return
+		}
+		panic(x)
+	}()
+
+	mailFrom := fm.MailFrom.XString(true)
+	rcptTo := fm.RcptTo.XString(true)
+	if redact {
+		header = redactHeader(header)
+		rcptTo = redactAddresses(rcptTo)
+	}
+	for _, c := range header {
+		if c >= 0x80 {
+			xc.Has8bit = true
+			break
+		}
+	}
+
+	xc.HeaderAddrs("From", []message.NameAddress{{Address: fromAddr}})
+	xc.HeaderAddrs("To", recipients)
+	xc.Subject(subject)
+	messageID = fmt.Sprintf("<%s>", mox.MessageIDGen(xc.SMTPUTF8))
+	xc.Header("Message-Id", messageID)
+	xc.Header("Date", time.Now().Format(message.RFC5322Z))
+	xc.Header("User-Agent", "mox/"+moxvar.Version)
+	xc.Header("MIME-Version", "1.0")
+
+	mp := multipart.NewWriter(xc)
+	xc.Header("Content-Type", fmt.Sprintf(`multipart/report; report-type="feedback-report"; boundary="%s"`, mp.Boundary()))
+	xc.Line()
+
+	var alignment []string
+	if fm.Result.AlignedDKIMPass {
+		alignment = append(alignment, "dkim")
+	}
+	if fm.Result.AlignedSPFPass {
+		alignment = append(alignment, "spf")
+	}
+	if len(alignment) == 0 {
+		alignment = []string{"none"}
+	}
+
+	text := fmt.Sprintf(`This is a DMARC failure report about a message we received with your domain in
+the message From header that failed DMARC authentication. You are receiving
+this message because your address is specified in the "ruf" field of the DMARC
+record for your domain.
+
+Reported domain: %s
+Source IP: %s
+Arrival date: %s
+Aligned passes: %s
+`, fm.Result.Domain, fm.SourceIP, fm.Arrival.Format(message.RFC5322Z), strings.Join(alignment, ", "))
+	if redact {
+		text += "\nEmail addresses of our users have been redacted.\n"
+	}
+	textBody, ct, cte := xc.TextPart("plain", text)
+	textHdr := textproto.MIMEHeader{}
+	textHdr.Set("Content-Type", ct)
+	textHdr.Set("Content-Transfer-Encoding", cte)
+	textp, err := mp.CreatePart(textHdr)
+	xc.Checkf(err, "adding text part to message")
+	_, err = textp.Write(textBody)
+	xc.Checkf(err, "writing text part")
+
+	// Machine-readable report, see RFC 5965, RFC 6591 and RFC 7489 section 7.3.
+	var report strings.Builder
+	field := func(k, v string) {
+		if v != "" {
+			fmt.Fprintf(&report, "%s: %s\r\n", k, v)
+		}
+	}
+	field("Feedback-Type", "auth-failure")
+	field("User-Agent", "mox/"+moxvar.Version)
+	field("Version", "1")
+	field("Original-Mail-From", "<"+mailFrom+">")
+	field("Original-Rcpt-To", "<"+rcptTo+">")
+	field("Arrival-Date", fm.Arrival.Format(message.RFC5322Z))
+	field("Source-IP", fm.SourceIP.String())
+	field("Reported-Domain", fm.Result.Domain.ASCII)
+	field("Authentication-Results", fm.AuthResults)
+	field("Auth-Failure", "dmarc")
+	field("Identity-Alignment", strings.Join(alignment, ", "))
+	if fm.Delivered {
+		field("Delivery-Result", "delivered")
+	} else {
+		field("Delivery-Result", "reject")
+	}
+	for _, dr := range fm.DKIMResults {
+		if dr.Status == dkim.StatusPass || dr.Sig == nil {
+			continue
+		}
+		field("DKIM-Domain", dr.Sig.Domain.ASCII)
+		field("DKIM-Selector", dr.Sig.Selector.ASCII)
+		break
+	}
+	reportHdr := textproto.MIMEHeader{}
+	reportHdr.Set("Content-Type", "message/feedback-report")
+	reportp, err := mp.CreatePart(reportHdr)
+	xc.Checkf(err, "adding feedback report part to message")
+	_, err = reportp.Write([]byte(report.String()))
+	xc.Checkf(err, "writing feedback report")
+
+	// Only the header of the message, never the body.
+	hdrHdr := textproto.MIMEHeader{}
+	if xc.Has8bit {
+		hdrHdr.Set("Content-Type", "message/global-headers")
+		hdrHdr.Set("Content-Transfer-Encoding", "8bit")
+	} else {
+		hdrHdr.Set("Content-Type", "text/rfc822-headers")
+	}
+	hdrp, err := mp.CreatePart(hdrHdr)
+	xc.Checkf(err, "adding header part to message")
+	_, err = hdrp.Write([]byte(header))
+	xc.Checkf(err, "writing header part")
+
+	err = mp.Close()
+	xc.Checkf(err, "closing multipart")
+
+	xc.Flush()
+
+	msgPrefix = dkimSign(ctx, log, fromAddr, xc.SMTPUTF8, mf)
+
+	return msgPrefix, xc.Has8bit, xc.SMTPUTF8, messageID, nil
+}
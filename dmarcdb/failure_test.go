@@ -0,0 +1,127 @@
+package dmarcdb
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dmarc"
+	"github.com/mjl-/mox/dmarcrpt"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/spf"
+)
+
+func TestFailureReport(t *testing.T) {
+	os.RemoveAll("../testdata/dmarcdb/data")
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/dmarcdb/mox.conf")
+	mox.ConfigDynamicPath = filepath.FromSlash("../testdata/dmarcdb/domains.conf")
+	mox.MustLoadConfig(true, false)
+	log := mlog.New("dmarcdb", nil)
+
+	err := Init()
+	tcheckf(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheckf(t, err, "close")
+	}()
+
+	mox.Conf.Static.OutgoingDMARCFailureReports = &config.DMARCFailureReports{Redact: true, MaxPerDay: 1}
+	defer func() {
+		mox.Conf.Static.OutgoingDMARCFailureReports = nil
+	}()
+
+	const msg = "From: <spoofer@sender.example>\r\nTo: <mjl@mox.example>\r\nSubject: test\r\nMessage-Id: <test@sender.example>\r\n\r\nsecret body\r\n"
+	fm := FailedMessage{
+		Result: dmarc.Result{
+			Domain: dns.Domain{ASCII: "sender.example"},
+			Record: &dmarc.Record{
+				Version:                 "DMARC1",
+				Policy:                  dmarc.PolicyReject,
+				FailureReportAddresses:  []dmarc.URI{{Address: "mailto:ruf@sender.example"}},
+				FailureReportingOptions: []string{"0"},
+			},
+		},
+		DKIMResults: []dkim.Result{{Status: dkim.StatusNone}},
+		SPFResult:   spf.StatusFail,
+		SourceIP:    net.ParseIP("10.1.2.3"),
+		MailFrom:    smtp.Path{Localpart: "spoofer", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "sender.example"}}},
+		RcptTo:      smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}},
+		Arrival:     time.Now(),
+		AuthResults: "mox.example; dmarc=fail header.from=sender.example",
+		Msg:         strings.NewReader(msg),
+	}
+
+	var queued []*dmarcrpt.FailureReport
+	queueAdd = func(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.File, qml ...queue.Msg) error {
+		if len(qml) != 1 || qml[0].Recipient().String() != "ruf@sender.example" {
+			t.Fatalf("unexpected queued messages %v", qml)
+		}
+		fr, err := dmarcrpt.ParseMessageFailureReport(log.Logger, msgFile)
+		tcheckf(t, err, "parsing generated failure report")
+		queued = append(queued, fr)
+		return nil
+	}
+	defer func() {
+		queueAdd = queue.Add
+	}()
+
+	// No report requested with fo=0 when there is an aligned pass.
+	fm.Result.AlignedSPFPass = true
+	err = SendFailureReport(ctxbg, log, dns.MockResolver{}, fm)
+	tcheckf(t, err, "send failure report")
+	if len(queued) != 0 {
+		t.Fatalf("got failure report for aligned pass")
+	}
+	fm.Result.AlignedSPFPass = false
+
+	err = SendFailureReport(ctxbg, log, dns.MockResolver{}, fm)
+	tcheckf(t, err, "send failure report")
+	if len(queued) != 1 {
+		t.Fatalf("got %d failure reports, expected 1", len(queued))
+	}
+	fr := queued[0]
+	if fr.FeedbackType != "auth-failure" || fr.ReportedDomain != "sender.example" || fr.SourceIP != "10.1.2.3" || fr.AuthFailure != "dmarc" || fr.IdentityAlignment != "none" || fr.DeliveryResult != "reject" {
+		t.Fatalf("unexpected failure report %#v", fr)
+	}
+	if fr.OriginalMailFrom != "<spoofer@sender.example>" || len(fr.OriginalRcptTo) != 1 || strings.Contains(fr.OriginalRcptTo[0], "mjl") || !strings.HasSuffix(fr.OriginalRcptTo[0], "@mox.example>") {
+		t.Fatalf("unexpected envelope in failure report %#v", fr)
+	}
+	if strings.Contains(fr.Header, "mjl@") || !strings.Contains(fr.Header, "From: <spoofer@sender.example>") || strings.Contains(fr.Header, "secret") {
+		t.Fatalf("unexpected header in failure report %q", fr.Header)
+	}
+
+	// Daily limit reached.
+	err = SendFailureReport(ctxbg, log, dns.MockResolver{}, fm)
+	tcheckf(t, err, "send failure report")
+	if len(queued) != 1 {
+		t.Fatalf("got %d failure reports, expected 1 due to limit", len(queued))
+	}
+
+	// Store incoming report.
+	err = AddFailureReport(ctxbg, fr, dns.Domain{ASCII: "reporter.example"})
+	tcheckf(t, err, "add failure report")
+	l, err := FailureReportsPeriodDomain(ctxbg, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "sender.example")
+	tcheckf(t, err, "listing failure reports")
+	if len(l) != 1 || l[0].FromDomain != "reporter.example" || l[0].SourceIP != "10.1.2.3" {
+		t.Fatalf("unexpected failure reports %#v", l)
+	}
+	r, err := FailureReportID(ctxbg, l[0].ID)
+	tcheckf(t, err, "get failure report")
+	tcompare(t, r.FailureReport, *fr)
+	l, err = FailureReportsPeriodDomain(ctxbg, time.Now().Add(-time.Hour), time.Now().Add(time.Hour), "other.example")
+	tcheckf(t, err, "listing failure reports")
+	if len(l) != 0 {
+		t.Fatalf("unexpected failure reports for other domain %#v", l)
+	}
+}
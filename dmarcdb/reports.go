@@ -15,8 +15,8 @@ import (
 )
 
 var (
-	ReportsDBTypes = []any{DomainFeedback{}} // Types stored in DB.
-	ReportsDB      *bstore.DB                // Exported for backups.
+	ReportsDBTypes = []any{DomainFeedback{}, FailureAlert{}, DomainFailureReport{}} // Types stored in DB.
+	ReportsDB      *bstore.DB                                                       // Exported for backups.
 )
 
 var (
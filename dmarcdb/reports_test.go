@@ -2,15 +2,20 @@ package dmarcdb
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
 	"time"
 
+	"github.com/mjl-/bstore"
+
 	"github.com/mjl-/mox/dmarcrpt"
 	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
 )
 
 var ctxbg = context.Background()
@@ -109,3 +114,124 @@ func TestDMARCDB(t *testing.T) {
 		t.Fatalf("records: got err %v, records %#v, expected no error and no records", err, records)
 	}
 }
+
+func TestAnalyticsFailureAlert(t *testing.T) {
+	mox.Shutdown = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/dmarcdb/mox.conf")
+	mox.ConfigDynamicPath = filepath.FromSlash("../testdata/dmarcdb/domains.conf")
+	mox.MustLoadConfig(true, false)
+	log := mlog.New("dmarcdb", nil)
+
+	os.RemoveAll(mox.DataDirPath("accounts"))
+	os.Remove(mox.DataDirPath("dmarcrpt.db"))
+	err := Init()
+	tcheckf(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheckf(t, err, "close")
+	}()
+	switchStop := store.Switchboard()
+	defer switchStop()
+
+	now := time.Now()
+	addReport := func(org string, begin time.Time, ip string, pass, fail int) {
+		t.Helper()
+		feedback := &dmarcrpt.Feedback{
+			ReportMetadata: dmarcrpt.ReportMetadata{
+				OrgName:   org,
+				ReportID:  fmt.Sprintf("%s-%d", org, begin.Unix()),
+				DateRange: dmarcrpt.DateRange{Begin: begin.Unix(), End: begin.Add(24*time.Hour - time.Second).Unix()},
+			},
+			PolicyPublished: dmarcrpt.PolicyPublished{Domain: "mox.example", Policy: "reject", Percentage: 100},
+			Records: []dmarcrpt.ReportRecord{
+				{
+					Row: dmarcrpt.Row{
+						SourceIP:        "10.0.0.1",
+						Count:           pass,
+						PolicyEvaluated: dmarcrpt.PolicyEvaluated{Disposition: dmarcrpt.DispositionNone, DKIM: dmarcrpt.DMARCPass, SPF: dmarcrpt.DMARCFail},
+					},
+					Identifiers: dmarcrpt.Identifiers{HeaderFrom: "mox.example"},
+					AuthResults: dmarcrpt.AuthResults{
+						DKIM: []dmarcrpt.DKIMAuthResult{{Domain: "mox.example", Result: dmarcrpt.DKIMPass}},
+						SPF:  []dmarcrpt.SPFAuthResult{{Domain: "bounce.mox.example", Result: dmarcrpt.SPFPass}},
+					},
+				},
+				{
+					Row: dmarcrpt.Row{
+						SourceIP:        ip,
+						Count:           fail,
+						PolicyEvaluated: dmarcrpt.PolicyEvaluated{Disposition: dmarcrpt.DispositionReject, DKIM: dmarcrpt.DMARCFail, SPF: dmarcrpt.DMARCFail},
+					},
+					Identifiers: dmarcrpt.Identifiers{HeaderFrom: "mox.example"},
+					AuthResults: dmarcrpt.AuthResults{
+						SPF: []dmarcrpt.SPFAuthResult{{Domain: "spammer.example", Result: dmarcrpt.SPFPass}},
+					},
+				},
+			},
+		}
+		err := AddReport(ctxbg, feedback, dns.Domain{ASCII: "reporter.example"})
+		tcheckf(t, err, "add report")
+	}
+
+	// Baseline with few failures, and recent reports with many.
+	day := now.Truncate(24 * time.Hour)
+	for i := 3; i < 9; i++ {
+		addReport("reporter.example", day.Add(-time.Duration(i)*24*time.Hour), "10.0.0.2", 100, 1)
+	}
+	addReport("reporter.example", day.Add(-24*time.Hour), "10.0.0.3", 50, 50)
+	addReport("other.example", day.Add(-24*time.Hour), "10.0.0.3", 10, 10)
+
+	a, err := Analyze(ctxbg, now.Add(-30*24*time.Hour), now, "mox.example", 1)
+	tcheckf(t, err, "analyze")
+	if a.Reports != 8 || a.Total != 6*101+100+20 || a.Fail != 6+60 || a.Pass != a.Total-a.Fail || a.Reject != a.Fail || a.DKIMAlignedPass != a.Pass || a.SPFAlignedPass != 0 || a.DKIMAuthPass != a.Pass || a.SPFAuthPass != a.Total {
+		t.Fatalf("unexpected analytics %#v", a)
+	}
+	if len(a.Days) != 7 || a.Days[6].Total != 120 || a.Days[6].Fail != 60 {
+		t.Fatalf("unexpected days %#v", a.Days)
+	}
+	if len(a.Sources) != 1 || a.Sources[0].IP != "10.0.0.1" || len(a.FailingSources) != 1 || a.FailingSources[0].IP != "10.0.0.3" || a.FailingSources[0].Fail != 60 {
+		t.Fatalf("unexpected sources %#v, failing sources %#v", a.Sources, a.FailingSources)
+	}
+	if len(a.Reporters) != 1 || a.Reporters[0].OrgName != "reporter.example" || a.Reporters[0].Reports != 7 {
+		t.Fatalf("unexpected reporters %#v", a.Reporters)
+	}
+
+	err = CheckFailureAlert(ctxbg, log, dns.Domain{ASCII: "mox.example"}, now)
+	tcheckf(t, err, "check failure alert")
+	fa := FailureAlert{Domain: "mox.example"}
+	err = ReportsDB.Get(ctxbg, &fa)
+	tcheckf(t, err, "get failure alert")
+	if !fa.Sent.Equal(now) {
+		t.Fatalf("failure alert sent %v, expected %v", fa.Sent, now)
+	}
+
+	// No second alert within a day.
+	err = CheckFailureAlert(ctxbg, log, dns.Domain{ASCII: "mox.example"}, now.Add(time.Hour))
+	tcheckf(t, err, "check failure alert")
+	err = ReportsDB.Get(ctxbg, &fa)
+	tcheckf(t, err, "get failure alert")
+	if !fa.Sent.Equal(now) {
+		t.Fatalf("failure alert sent %v, expected %v", fa.Sent, now)
+	}
+
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheckf(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		tcheckf(t, err, "close account")
+		acc.WaitClosed()
+	}()
+	var n int
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		mb, err := acc.MailboxFind(tx, "DMARC")
+		if err != nil || mb == nil {
+			return fmt.Errorf("finding mailbox: %v", err)
+		}
+		n, err = bstore.QueryTx[store.Message](tx).FilterNonzero(store.Message{MailboxID: mb.ID}).Count()
+		return err
+	})
+	tcheckf(t, err, "count messages")
+	if n != 1 {
+		t.Fatalf("got %d alert messages, expected 1", n)
+	}
+}
@@ -0,0 +1,127 @@
+package dmarcrpt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/textproto"
+	"strings"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+)
+
+var ErrNoFailureReport = errors.New("no dmarc failure report found in message")
+
+// FailureReport is a DMARC failure report, also known as forensic report, about
+// a single message that failed authentication. It is sent in the Abuse Reporting
+// Format (ARF), as a message/feedback-report part in a multipart/report message,
+// with the (possibly redacted) headers of the message, see RFC 6591 and RFC 7489
+// section 7.3.
+type FailureReport struct {
+	FeedbackType          string   // Should be "auth-failure".
+	UserAgent             string   // Software that generated the report.
+	Version               string   // Should be "1".
+	OriginalMailFrom      string   // SMTP MAIL FROM of reported message.
+	OriginalRcptTo        []string // SMTP RCPT TO of reported message.
+	ArrivalDate           string   // In message date-time format.
+	SourceIP              string   // IP of the sending mail server.
+	ReportedDomain        string   // Domain the report is about, the message From domain.
+	AuthenticationResults []string
+	AuthFailure           string // E.g. "dmarc", "dkim", "spf".
+	IdentityAlignment     string // Mechanisms that passed with alignment, "none", or e.g. "dkim, spf".
+	DeliveryResult        string // "delivered", "spam", "policy", "reject", "other".
+	DKIMDomain            string
+	DKIMIdentity          string
+	DKIMSelector          string
+	SPFDNS                string
+
+	// Header of the reported message, possibly redacted. Some reporters include the
+	// full message, we only keep the header.
+	Header string
+}
+
+// ParseMessageFailureReport parses a failure report from a mail message. The
+// maximum message size is 15MB.
+func ParseMessageFailureReport(elog *slog.Logger, r io.ReaderAt) (*FailureReport, error) {
+	log := mlog.New("dmarcrpt", elog)
+	p, err := message.Parse(log.Logger, true, &moxio.LimitAtReader{R: r, Limit: 15 * 1024 * 1024})
+	if err != nil {
+		return nil, fmt.Errorf("parsing mail message: %s", err)
+	}
+
+	if p.MediaType != "MULTIPART" || p.MediaSubType != "REPORT" || !strings.EqualFold(p.ContentTypeParams["report-type"], "feedback-report") {
+		return nil, ErrNoFailureReport
+	}
+
+	var report *FailureReport
+	var header string
+	for {
+		sp, err := p.ParseNextPart(log.Logger)
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch sp.MediaType + "/" + sp.MediaSubType {
+		case "MESSAGE/FEEDBACK-REPORT":
+			report, err = parseFailureReport(sp.Reader())
+			if err != nil {
+				return nil, err
+			}
+		case "TEXT/RFC822-HEADERS", "MESSAGE/RFC822", "MESSAGE/GLOBAL", "MESSAGE/GLOBAL-HEADERS":
+			// A text/rfc822-headers part may not have an empty line after the header.
+			buf, err := io.ReadAll(&moxio.LimitReader{R: sp.Reader(), Limit: 256 * 1024})
+			if err != nil {
+				return nil, fmt.Errorf("reading header of reported message: %v", err)
+			}
+			if i := bytes.Index(buf, []byte("\r\n\r\n")); i >= 0 {
+				buf = buf[:i+2]
+			}
+			header = string(buf)
+		}
+	}
+	if report == nil {
+		return nil, ErrNoFailureReport
+	}
+	report.Header = header
+	return report, nil
+}
+
+func parseFailureReport(r io.Reader) (*FailureReport, error) {
+	// The report has the syntax of a message header, see RFC 5965.
+	h, err := textproto.NewReader(bufio.NewReader(&moxio.LimitReader{R: r, Limit: 64 * 1024})).ReadMIMEHeader()
+	if err != nil && !(err == io.EOF && len(h) > 0) {
+		return nil, fmt.Errorf("parsing feedback report: %v", err)
+	}
+	report := FailureReport{
+		FeedbackType:          strings.ToLower(h.Get("Feedback-Type")),
+		UserAgent:             h.Get("User-Agent"),
+		Version:               h.Get("Version"),
+		OriginalMailFrom:      h.Get("Original-Mail-From"),
+		OriginalRcptTo:        h.Values("Original-Rcpt-To"),
+		ArrivalDate:           h.Get("Arrival-Date"),
+		SourceIP:              h.Get("Source-Ip"),
+		ReportedDomain:        strings.ToLower(h.Get("Reported-Domain")),
+		AuthenticationResults: h.Values("Authentication-Results"),
+		AuthFailure:           strings.ToLower(h.Get("Auth-Failure")),
+		IdentityAlignment:     strings.ToLower(h.Get("Identity-Alignment")),
+		DeliveryResult:        strings.ToLower(h.Get("Delivery-Result")),
+		DKIMDomain:            h.Get("Dkim-Domain"),
+		DKIMIdentity:          h.Get("Dkim-Identity"),
+		DKIMSelector:          h.Get("Dkim-Selector"),
+		SPFDNS:                h.Get("Spf-Dns"),
+	}
+	if report.ArrivalDate == "" {
+		// Older name for the field.
+		report.ArrivalDate = h.Get("Received-Date")
+	}
+	if report.FeedbackType != "auth-failure" {
+		return nil, ErrNoFailureReport
+	}
+	return &report, nil
+}
@@ -0,0 +1,84 @@
+package dmarcrpt
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMessageFailureReport(t *testing.T) {
+	// Example from RFC 6591, with CRLF line endings.
+	const msg = `From: dmarc@mail-receiver.example
+To: ruf@example.com
+Subject: FW: Discount on items you purchased
+Date: Thu, 8 Mar 2005 17:40:36 EDT
+MIME-Version: 1.0
+Content-Type: multipart/report; report-type=feedback-report;
+     boundary="part1_13d.2e68ed54_boundary"
+
+--part1_13d.2e68ed54_boundary
+Content-Type: text/plain; charset="US-ASCII"
+Content-Transfer-Encoding: 7bit
+
+This is an authentication failure report for an email message received
+from IP 192.0.2.1 on Thu, 8 Mar 2005 14:00:00 EDT.
+
+--part1_13d.2e68ed54_boundary
+Content-Type: message/feedback-report
+
+Feedback-Type: auth-failure
+User-Agent: SomeDKIMFilter/1.0
+Version: 1
+Original-Mail-From: <sender@example.com>
+Original-Rcpt-To: <user@mail-receiver.example>
+Received-Date: Thu, 8 Mar 2005 14:00:00 EDT
+Source-IP: 192.0.2.1
+Authentication-Results: mail-receiver.example;
+      dkim=fail header.d=example.com
+Reported-Domain: example.com
+Auth-Failure: dmarc
+Identity-Alignment: none
+DKIM-Domain: example.com
+DKIM-Selector: news
+
+--part1_13d.2e68ed54_boundary
+Content-Type: text/rfc822-headers
+
+From: <sender@example.com>
+To: <user@mail-receiver.example>
+Subject: Discount on items you purchased
+Message-ID: <8787KJKJ3K4J3K4J3K4J3.mail@example.com>
+
+--part1_13d.2e68ed54_boundary--
+`
+
+	r, err := ParseMessageFailureReport(pkglog.Logger, strings.NewReader(strings.ReplaceAll(msg, "\n", "\r\n")))
+	if err != nil {
+		t.Fatalf("parsing failure report: %v", err)
+	}
+	exp := &FailureReport{
+		FeedbackType:          "auth-failure",
+		UserAgent:             "SomeDKIMFilter/1.0",
+		Version:               "1",
+		OriginalMailFrom:      "<sender@example.com>",
+		OriginalRcptTo:        []string{"<user@mail-receiver.example>"},
+		ArrivalDate:           "Thu, 8 Mar 2005 14:00:00 EDT",
+		SourceIP:              "192.0.2.1",
+		ReportedDomain:        "example.com",
+		AuthenticationResults: []string{"mail-receiver.example; dkim=fail header.d=example.com"},
+		AuthFailure:           "dmarc",
+		IdentityAlignment:     "none",
+		DKIMDomain:            "example.com",
+		DKIMSelector:          "news",
+		Header:                "From: <sender@example.com>\r\nTo: <user@mail-receiver.example>\r\nSubject: Discount on items you purchased\r\nMessage-ID: <8787KJKJ3K4J3K4J3K4J3.mail@example.com>\r\n",
+	}
+	if !reflect.DeepEqual(r, exp) {
+		t.Fatalf("got:\n%#v\nexpected:\n%#v", r, exp)
+	}
+
+	// Not a failure report.
+	_, err = ParseMessageFailureReport(pkglog.Logger, strings.NewReader("Subject: test\r\n\r\ntest\r\n"))
+	if err != ErrNoFailureReport {
+		t.Fatalf("got err %v, expected ErrNoFailureReport", err)
+	}
+}
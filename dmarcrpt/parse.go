@@ -1,4 +1,4 @@
-// Package dmarcrpt parses DMARC aggregate feedback reports.
+// Package dmarcrpt parses DMARC aggregate feedback reports and failure reports.
 package dmarcrpt
 
 import (
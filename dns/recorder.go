@@ -0,0 +1,122 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/mjl-/adns"
+)
+
+// Lookup is a DNS lookup made through a Recorder, with its DNSSEC status.
+type Lookup struct {
+	Type      string // E.g. "mx", "ip", "cname", "tlsa".
+	Name      string // Requested name. For TLSA, the full name, e.g. _25._tcp.<host>.
+	Authentic bool   // Whether the response, or the nonexistence of records, was DNSSEC-verified by the recursive resolver.
+	Error     string // Error for the lookup, including "not found" errors.
+}
+
+// Recorder is a Resolver that records the lookups it passes on to Resolver,
+// along with whether the responses were DNSSEC-verified. Used to show which
+// security guarantees applied to an operation, such as an outgoing delivery
+// attempt. Safe for concurrent use.
+type Recorder struct {
+	Resolver Resolver
+
+	mutex   sync.Mutex
+	lookups []Lookup
+}
+
+var _ Resolver = (*Recorder)(nil)
+
+// NewRecorder returns a Recorder that passes lookups on to resolver.
+func NewRecorder(resolver Resolver) *Recorder {
+	return &Recorder{Resolver: resolver}
+}
+
+// Lookups returns a copy of the lookups recorded so far, in order.
+func (r *Recorder) Lookups() []Lookup {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]Lookup(nil), r.lookups...)
+}
+
+func (r *Recorder) record(typ, name string, result adns.Result, err error) {
+	l := Lookup{Type: typ, Name: name, Authentic: result.Authentic}
+	if err != nil {
+		l.Error = err.Error()
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lookups = append(r.lookups, l)
+}
+
+// LookupPort is not recorded, it does not involve DNS.
+func (r *Recorder) LookupPort(ctx context.Context, network, service string) (int, error) {
+	return r.Resolver.LookupPort(ctx, network, service)
+}
+
+func (r *Recorder) LookupAddr(ctx context.Context, addr string) ([]string, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupAddr(ctx, addr)
+	r.record("ptr", addr, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupCNAME(ctx context.Context, host string) (string, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupCNAME(ctx, host)
+	r.record("cname", host, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupHost(ctx context.Context, host string) ([]string, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupHost(ctx, host)
+	r.record("host", host, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupIP(ctx context.Context, network, host string) ([]net.IP, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupIP(ctx, network, host)
+	r.record(network, host, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupIPAddr(ctx, host)
+	r.record("ip", host, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupMX(ctx context.Context, name string) ([]*net.MX, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupMX(ctx, name)
+	r.record("mx", name, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupNS(ctx context.Context, name string) ([]*net.NS, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupNS(ctx, name)
+	r.record("ns", name, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, adns.Result, error) {
+	cname, resp, result, err := r.Resolver.LookupSRV(ctx, service, proto, name)
+	r.record("srv", fmt.Sprintf("_%s._%s.%s", service, proto, name), result, err)
+	return cname, resp, result, err
+}
+
+func (r *Recorder) LookupTXT(ctx context.Context, name string) ([]string, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupTXT(ctx, name)
+	r.record("txt", name, result, err)
+	return resp, result, err
+}
+
+func (r *Recorder) LookupTLSA(ctx context.Context, port int, protocol, host string) ([]adns.TLSA, adns.Result, error) {
+	resp, result, err := r.Resolver.LookupTLSA(ctx, port, protocol, host)
+	name := fmt.Sprintf("_%d._%s.%s", port, protocol, host)
+	if port == 0 && protocol == "" {
+		name = host
+	}
+	r.record("tlsa", name, result, err)
+	return resp, result, err
+}
@@ -0,0 +1,30 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+	resolver := MockResolver{
+		MX:        map[string][]*net.MX{"example.com.": {{Host: "mx.example.com.", Pref: 10}}},
+		A:         map[string][]string{"mx.example.com.": {"10.0.0.1"}},
+		Authentic: []string{"mx example.com."},
+	}
+	r := NewRecorder(resolver)
+	ctx := context.Background()
+	r.LookupMX(ctx, "example.com.")
+	r.LookupIP(ctx, "ip", "mx.example.com.")
+	r.LookupTLSA(ctx, 25, "tcp", "mx.example.com.")
+
+	exp := []Lookup{
+		{Type: "mx", Name: "example.com.", Authentic: true},
+		{Type: "ip", Name: "mx.example.com."},
+		{Type: "tlsa", Name: "_25._tcp.mx.example.com.", Error: "lookup _25._tcp.mx.example.com. on mock: no record"},
+	}
+	if l := r.Lookups(); !reflect.DeepEqual(l, exp) {
+		t.Fatalf("got lookups %#v, expected %#v", l, exp)
+	}
+}
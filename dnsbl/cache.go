@@ -0,0 +1,165 @@
+package dnsbl
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+)
+
+// Durations for which lookup results are cached. The resolver does not expose the
+// TTLs of DNS records, so fixed durations are used, shorter than typical TTLs of
+// DNSBL records. Results of lookups that failed with a temporary error are cached
+// briefly, so a list that times out doesn't slow down each lookup.
+const (
+	CacheListedTTL    = time.Hour
+	CacheNotListedTTL = 15 * time.Minute
+	CacheTemperrTTL   = time.Minute
+)
+
+// HealthInterval is the maximum age of a health check of a zone before Healthy
+// checks again.
+const HealthInterval = 4 * time.Hour
+
+// After this many consecutive temporary errors, for lookups and health checks, a
+// zone is considered dead until its next successful health check.
+const maxTemperrs = 5
+
+// Maximum number of cached lookup results. When full, expired entries are
+// removed, and if still full, all entries.
+const maxCacheEntries = 10000
+
+type cacheKey struct {
+	zone string
+	ip   string
+}
+
+type cacheEntry struct {
+	status      Status
+	explanation string
+	err         error
+	expires     time.Time
+}
+
+type zoneHealth struct {
+	checked  time.Time // Time of last health check.
+	err      error     // Result of last health check: nil, ErrDNS or other.
+	temperrs int       // Consecutive temporary errors.
+}
+
+// Cached lookup results and health of zones, shared by all users in the process.
+var cache = struct {
+	sync.Mutex
+	m     map[cacheKey]cacheEntry
+	zones map[dns.Domain]zoneHealth
+}{
+	m:     map[cacheKey]cacheEntry{},
+	zones: map[dns.Domain]zoneHealth{},
+}
+
+// ResetCache removes all cached lookup results and health of zones.
+func ResetCache() {
+	cache.Lock()
+	defer cache.Unlock()
+	cache.m = map[cacheKey]cacheEntry{}
+	cache.zones = map[dns.Domain]zoneHealth{}
+}
+
+// LookupCached is like Lookup, but returns a cached result if available. Listed
+// and not listed results, and temporary errors are cached, for CacheListedTTL,
+// CacheNotListedTTL and CacheTemperrTTL. Temporary errors count towards
+// considering the zone dead, see Healthy.
+func LookupCached(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, zone dns.Domain, ip net.IP) (Status, string, error) {
+	key := cacheKey{zone.ASCII, ip.String()}
+	now := time.Now()
+	cache.Lock()
+	e, ok := cache.m[key]
+	cache.Unlock()
+	if ok && now.Before(e.expires) {
+		MetricCache.IncLabels("hit")
+		return e.status, e.explanation, e.err
+	}
+	MetricCache.IncLabels("miss")
+
+	status, expl, err := Lookup(ctx, elog, resolver, zone, ip)
+	var ttl time.Duration
+	switch status {
+	case StatusPass:
+		ttl = CacheNotListedTTL
+	case StatusFail:
+		ttl = CacheListedTTL
+	default:
+		ttl = CacheTemperrTTL
+	}
+
+	cache.Lock()
+	defer cache.Unlock()
+	zh := cache.zones[zone]
+	if status == StatusTemperr {
+		zh.temperrs++
+	} else {
+		zh.temperrs = 0
+	}
+	cache.zones[zone] = zh
+
+	if len(cache.m) >= maxCacheEntries {
+		for k, e := range cache.m {
+			if !now.Before(e.expires) {
+				delete(cache.m, k)
+			}
+		}
+		if len(cache.m) >= maxCacheEntries {
+			cache.m = map[cacheKey]cacheEntry{}
+		}
+	}
+	cache.m[key] = cacheEntry{status, expl, err, now.Add(ttl)}
+	return status, expl, err
+}
+
+// CheckZoneHealth checks the health of zone with CheckHealth, and stores the
+// result for Healthy. A temporary error counts towards considering the zone dead,
+// a successful check makes a dead zone healthy again.
+func CheckZoneHealth(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, zone dns.Domain) error {
+	err := CheckHealth(ctx, elog, resolver, zone)
+
+	cache.Lock()
+	defer cache.Unlock()
+	zh := cache.zones[zone]
+	zh.checked = time.Now()
+	zh.err = err
+	if err == nil {
+		zh.temperrs = 0
+	} else if errors.Is(err, ErrDNS) {
+		zh.temperrs++
+	}
+	cache.zones[zone] = zh
+	return err
+}
+
+// Healthy returns whether zone can be used for lookups. If the health of zone
+// wasn't checked in the past HealthInterval, it is checked first. A zone is not
+// healthy if its last health check failed with a non-temporary error, e.g.
+// because the list has been shut down and lists all IPs, or if too many
+// consecutive lookups and health checks failed with a temporary error, e.g. a
+// timeout.
+func Healthy(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, zone dns.Domain) bool {
+	cache.Lock()
+	zh, ok := cache.zones[zone]
+	cache.Unlock()
+	if !ok || zh.checked.IsZero() || time.Since(zh.checked) > HealthInterval {
+		CheckZoneHealth(ctx, elog, resolver, zone)
+		cache.Lock()
+		zh = cache.zones[zone]
+		cache.Unlock()
+	}
+	if zh.temperrs >= maxTemperrs {
+		mlog.New("dnsbl", elog).Debug("dnsbl considered dead after temporary errors", slog.Any("zone", zone), slog.Int("temperrors", zh.temperrs))
+		return false
+	}
+	return zh.err == nil || errors.Is(zh.err, ErrDNS)
+}
@@ -12,6 +12,9 @@
 //
 // The health of a DNSBL "zone" can be check through a lookup of 127.0.0.1
 // (must not be present) and 127.0.0.2 (must be present).
+//
+// LookupCached and Healthy keep a process-wide cache of lookup results and the
+// health of zones, disabling zones that are dead.
 package dnsbl
 
 import (
@@ -31,6 +34,7 @@ import (
 
 var (
 	MetricLookup stub.HistogramVec = stub.HistogramVecIgnore{}
+	MetricCache  stub.CounterVec   = stub.CounterVecIgnore{}
 )
 
 var ErrDNS = errors.New("dnsbl: dns error") // Temporary error.
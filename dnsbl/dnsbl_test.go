@@ -3,6 +3,7 @@ package dnsbl
 import (
 	"context"
 	"net"
+	"strconv"
 	"testing"
 
 	"github.com/mjl-/mox/dns"
@@ -64,3 +65,61 @@ func TestDNSBL(t *testing.T) {
 		t.Fatalf("bad dnsbl is healthy")
 	}
 }
+
+func TestLookupCached(t *testing.T) {
+	ctx := context.Background()
+	log := mlog.New("dnsbl", nil)
+	ResetCache()
+	defer ResetCache()
+
+	zone := dns.Domain{ASCII: "example.com"}
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"2.0.0.127.example.com.": {"127.0.0.2"},
+			"1.0.0.10.example.com.":  {"127.0.0.2"},
+		},
+	}
+
+	lookup := func(resolver dns.Resolver, ip string, exp Status) {
+		t.Helper()
+		status, _, _ := LookupCached(ctx, log.Logger, resolver, zone, net.ParseIP(ip))
+		if status != exp {
+			t.Fatalf("lookup %s, got status %v, expected %v", ip, status, exp)
+		}
+	}
+
+	if !Healthy(ctx, log.Logger, resolver, zone) {
+		t.Fatalf("dnsbl not healthy")
+	}
+	lookup(resolver, "10.0.0.1", StatusFail)
+	lookup(resolver, "10.0.0.2", StatusPass)
+
+	// Results are cached, also when the list changes.
+	changed := dns.MockResolver{A: map[string][]string{"2.0.0.10.example.com.": {"127.0.0.2"}}}
+	lookup(changed, "10.0.0.1", StatusFail)
+	lookup(changed, "10.0.0.2", StatusPass)
+
+	// Consecutive temporary errors make the list dead, until its next successful
+	// health check.
+	failing := dns.MockResolver{Fail: []string{"ip 3.0.0.10.example.com.", "ip 4.0.0.10.example.com.", "ip 5.0.0.10.example.com.", "ip 6.0.0.10.example.com.", "ip 7.0.0.10.example.com."}}
+	for i := 3; i <= 7; i++ {
+		lookup(failing, "10.0.0."+strconv.Itoa(i), StatusTemperr)
+	}
+	if Healthy(ctx, log.Logger, resolver, zone) {
+		t.Fatalf("dead dnsbl is healthy")
+	}
+	if err := CheckZoneHealth(ctx, log.Logger, resolver, zone); err != nil {
+		t.Fatalf("health check: %v", err)
+	}
+	if !Healthy(ctx, log.Logger, resolver, zone) {
+		t.Fatalf("dnsbl not healthy after health check")
+	}
+
+	// A list that fails its health check is not healthy.
+	if err := CheckZoneHealth(ctx, log.Logger, changed, zone); err == nil {
+		t.Fatalf("health check of bad dnsbl succeeded")
+	}
+	if Healthy(ctx, log.Logger, changed, zone) {
+		t.Fatalf("bad dnsbl is healthy")
+	}
+}
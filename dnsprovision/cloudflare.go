@@ -0,0 +1,162 @@
+package dnsprovision
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+)
+
+// cloudflare uses the Cloudflare API v4.
+type cloudflare struct {
+	token   string
+	baseURL string
+
+	sync.Mutex
+	zoneIDs map[string]string // By zone name.
+}
+
+func newCloudflare(c config.DNSProviderToken) *cloudflare {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.cloudflare.com/client/v4"
+	}
+	return &cloudflare{token: c.APIToken, baseURL: strings.TrimSuffix(baseURL, "/"), zoneIDs: map[string]string{}}
+}
+
+type cloudflareResponse struct {
+	Success bool
+	Errors  []struct {
+		Code    int
+		Message string
+	}
+	Result any
+}
+
+type cloudflareRecord struct {
+	ID      string `json:"id,omitempty"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content,omitempty"`
+	TTL     int    `json:"ttl"`
+	Data    any    `json:"data,omitempty"`
+}
+
+func (p *cloudflare) call(ctx context.Context, method, path string, reqBody, result any) error {
+	req, err := newRequest(method, p.baseURL+path, reqBody)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	resp := cloudflareResponse{Result: result}
+	if _, err := httpDo(ctx, req, &resp); err != nil {
+		return err
+	}
+	if !resp.Success {
+		var msgs []string
+		for _, e := range resp.Errors {
+			msgs = append(msgs, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return fmt.Errorf("%w: %s %s: %s", ErrProvider, method, path, strings.Join(msgs, "; "))
+	}
+	return nil
+}
+
+func (p *cloudflare) zoneID(ctx context.Context, zone dns.Domain) (string, error) {
+	p.Lock()
+	id, ok := p.zoneIDs[zone.ASCII]
+	p.Unlock()
+	if ok {
+		return id, nil
+	}
+
+	var zones []struct{ ID string }
+	if err := p.call(ctx, "GET", "/zones?name="+url.QueryEscape(zone.ASCII), nil, &zones); err != nil {
+		return "", err
+	}
+	if len(zones) != 1 {
+		return "", fmt.Errorf("%w: zone %s not found", ErrProvider, zone)
+	}
+	p.Lock()
+	p.zoneIDs[zone.ASCII] = zones[0].ID
+	p.Unlock()
+	return zones[0].ID, nil
+}
+
+func (p *cloudflare) list(ctx context.Context, zone dns.Domain, name, typ string) (zoneID string, records []cloudflareRecord, rerr error) {
+	zoneID, err := p.zoneID(ctx, zone)
+	if err != nil {
+		return "", nil, err
+	}
+	q := url.Values{"type": {typ}, "name": {name}, "per_page": {"100"}}
+	err = p.call(ctx, "GET", "/zones/"+zoneID+"/dns_records?"+q.Encode(), nil, &records)
+	return zoneID, records, err
+}
+
+func (p *cloudflare) Records(ctx context.Context, zone dns.Domain, name, typ string) ([]string, error) {
+	_, records, err := p.list(ctx, zone, name, typ)
+	if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, r := range records {
+		v, err := normalizeValue(typ, r.Content)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (p *cloudflare) SetRecords(ctx context.Context, zone dns.Domain, name, typ string, ttl int, values []string) error {
+	zoneID, records, err := p.list(ctx, zone, name, typ)
+	if err != nil {
+		return err
+	}
+
+	// Remove records we don't want, and add records that are missing.
+	have := map[string]bool{}
+	for _, r := range records {
+		v, err := normalizeValue(typ, r.Content)
+		if err == nil && slices.Contains(values, v) && !have[v] {
+			have[v] = true
+			continue
+		}
+		if err := p.call(ctx, "DELETE", "/zones/"+zoneID+"/dns_records/"+url.PathEscape(r.ID), nil, nil); err != nil {
+			return err
+		}
+	}
+	for _, v := range values {
+		if have[v] {
+			continue
+		}
+		r := cloudflareRecord{Type: typ, Name: name, TTL: ttl}
+		switch typ {
+		case "TXT":
+			r.Content = txtQuote(v)
+		case "TLSA":
+			data, err := tlsaData(v)
+			if err != nil {
+				return err
+			}
+			r.Data = map[string]any{
+				"usage":         data[0],
+				"selector":      data[1],
+				"matching_type": data[2],
+				"certificate":   fmt.Sprintf("%x", data[3:]),
+			}
+		default:
+			return fmt.Errorf("unsupported record type %q", typ)
+		}
+		if err := p.call(ctx, "POST", "/zones/"+zoneID+"/dns_records", r, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package dnsprovision
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+)
+
+// deSEC does not allow TTLs lower than an hour for regular accounts.
+const desecMinTTL = 3600
+
+// desec uses the deSEC.io API.
+type desec struct {
+	token   string
+	baseURL string
+}
+
+func newDeSEC(c config.DNSProviderToken) *desec {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = "https://desec.io/api/v1"
+	}
+	return &desec{token: c.APIToken, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+type desecRRset struct {
+	Subname string   `json:"subname"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+func (p *desec) Records(ctx context.Context, zone dns.Domain, name, typ string) ([]string, error) {
+	sub, err := relativeName(zone, name)
+	if err != nil {
+		return nil, err
+	}
+	// The zone apex is "@" in the URL.
+	if sub == "" {
+		sub = "@"
+	}
+	req, err := newRequest("GET", p.baseURL+"/domains/"+url.PathEscape(zone.ASCII)+"/rrsets/"+url.PathEscape(sub)+"/"+typ+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Token "+p.token)
+	var rrset desecRRset
+	if notFound, err := httpDo(ctx, req, &rrset); notFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var values []string
+	for _, r := range rrset.Records {
+		v, err := normalizeValue(typ, r)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (p *desec) SetRecords(ctx context.Context, zone dns.Domain, name, typ string, ttl int, values []string) error {
+	sub, err := relativeName(zone, name)
+	if err != nil {
+		return err
+	}
+	rrset := desecRRset{Subname: sub, Type: typ, TTL: max(ttl, desecMinTTL), Records: []string{}}
+	for _, v := range values {
+		if typ == "TXT" {
+			v = txtQuote(v)
+		}
+		rrset.Records = append(rrset.Records, v)
+	}
+	// Bulk update replaces the RRset, and removes it if there are no records.
+	req, err := newRequest("PUT", p.baseURL+"/domains/"+url.PathEscape(zone.ASCII)+"/rrsets/", []desecRRset{rrset})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Token "+p.token)
+	_, err = httpDo(ctx, req, nil)
+	return err
+}
@@ -0,0 +1,273 @@
+// Package dnsprovision changes DNS records through DNS provider APIs.
+//
+// Providers implement a small interface for reading and replacing the records of
+// a type at a name. Implemented are DNS UPDATE (RFC 2136) with TSIG, and the
+// HTTP APIs of Cloudflare, deSEC and Amazon Route 53. Only record types TXT and
+// TLSA are used and supported.
+//
+// Record values are in presentation format without quoting: the text for TXT
+// records (long texts are split into multiple strings by the providers), and e.g.
+// "3 1 1 <hex>" for TLSA records.
+package dnsprovision
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxvar"
+)
+
+var pkglog = mlog.New("dnsprovision", nil)
+
+// ErrProvider is returned for errors from the provider, e.g. failed requests.
+var ErrProvider = errors.New("dns provider error")
+
+// Provider changes records in DNS zones.
+//
+// Names are fully qualified domain names in ASCII, without trailing dot. Types
+// are e.g. "TXT".
+type Provider interface {
+	// Records returns the values of the records of type typ at name. No records is
+	// not an error.
+	Records(ctx context.Context, zone dns.Domain, name, typ string) ([]string, error)
+
+	// SetRecords replaces the records of type typ at name with values. If values is
+	// empty, the records are removed.
+	SetRecords(ctx context.Context, zone dns.Domain, name, typ string, ttl int, values []string) error
+}
+
+// New returns a provider for the configuration.
+func New(c config.DNSProvider) (Provider, error) {
+	switch {
+	case c.RFC2136 != nil:
+		return newRFC2136(*c.RFC2136)
+	case c.Cloudflare != nil:
+		return newCloudflare(*c.Cloudflare), nil
+	case c.DeSEC != nil:
+		return newDeSEC(*c.DeSEC), nil
+	case c.Route53 != nil:
+		return newRoute53(*c.Route53), nil
+	}
+	return nil, fmt.Errorf("no provider type configured")
+}
+
+// Record is a set of records managed by mox.
+type Record struct {
+	Name   string // FQDN, ASCII, without trailing dot.
+	Type   string
+	Values []string
+
+	// If set, only existing values starting with this prefix are replaced by Values,
+	// other values are kept. E.g. "v=spf1 " for SPF records at a name that can also
+	// have other TXT records.
+	Prefix string
+}
+
+// Sync ensures the records are present in the zone, changing only records that
+// differ. The names of changed records are returned.
+func Sync(ctx context.Context, log mlog.Log, p Provider, zone dns.Domain, ttl int, records []Record) (changed []string, rerr error) {
+	for _, r := range records {
+		cur, err := p.Records(ctx, zone, r.Name, r.Type)
+		if err != nil {
+			return changed, fmt.Errorf("get %s records for %s: %w", r.Type, r.Name, err)
+		}
+
+		var values []string
+		if r.Prefix != "" {
+			for _, v := range cur {
+				if !strings.HasPrefix(v, r.Prefix) {
+					values = append(values, v)
+				}
+			}
+		}
+		values = append(values, r.Values...)
+
+		if equalValues(cur, values) {
+			continue
+		}
+		if err := p.SetRecords(ctx, zone, r.Name, r.Type, ttl, values); err != nil {
+			return changed, fmt.Errorf("set %s records for %s: %w", r.Type, r.Name, err)
+		}
+		log.Info("dns records updated", slog.String("name", r.Name), slog.String("type", r.Type), slog.Any("values", values))
+		changed = append(changed, r.Type+" "+r.Name)
+	}
+	return changed, nil
+}
+
+func equalValues(a, b []string) bool {
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// relativeName returns name relative to zone, "" for the zone apex.
+func relativeName(zone dns.Domain, name string) (string, error) {
+	name = strings.ToLower(name)
+	z := strings.ToLower(zone.ASCII)
+	if name == z {
+		return "", nil
+	} else if strings.HasSuffix(name, "."+z) {
+		return strings.TrimSuffix(name, "."+z), nil
+	}
+	return "", fmt.Errorf("name %s not in zone %s", name, zone)
+}
+
+// txtStrings splits a text into strings of at most 255 bytes, for TXT records.
+func txtStrings(s string) []string {
+	var l []string
+	for len(s) > 255 {
+		l = append(l, s[:255])
+		s = s[255:]
+	}
+	return append(l, s)
+}
+
+// txtQuote returns the text quoted for use in zone files, split into strings of
+// at most 255 bytes.
+func txtQuote(s string) string {
+	var l []string
+	for _, t := range txtStrings(s) {
+		t = strings.ReplaceAll(t, `\`, `\\`)
+		t = strings.ReplaceAll(t, `"`, `\"`)
+		l = append(l, `"`+t+`"`)
+	}
+	return strings.Join(l, " ")
+}
+
+// txtUnquote parses a quoted text from zone file syntax, concatenating multiple
+// strings. Texts without quotes are returned as is.
+func txtUnquote(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, `"`) {
+		return s, nil
+	}
+	var r strings.Builder
+	for s != "" {
+		if s[0] == ' ' || s[0] == '\t' {
+			s = s[1:]
+			continue
+		}
+		if s[0] != '"' {
+			return "", fmt.Errorf("%w: expected quoted string in txt value", ErrProvider)
+		}
+		s = s[1:]
+		for {
+			if s == "" {
+				return "", fmt.Errorf("%w: unterminated string in txt value", ErrProvider)
+			}
+			c := s[0]
+			s = s[1:]
+			if c == '"' {
+				break
+			} else if c == '\\' && s != "" {
+				c = s[0]
+				s = s[1:]
+			}
+			r.WriteByte(c)
+		}
+	}
+	return r.String(), nil
+}
+
+// tlsaNormalize returns a TLSA record in presentation format as generated by mox,
+// with lower case hexadecimal data.
+func tlsaNormalize(v string) (string, error) {
+	data, err := tlsaData(v)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	return fmt.Sprintf("%d %d %d %x", data[0], data[1], data[2], data[3:]), nil
+}
+
+// normalizeValue returns a value as returned by a provider API in the format of
+// Provider.Records.
+func normalizeValue(typ, v string) (string, error) {
+	switch typ {
+	case "TXT":
+		return txtUnquote(v)
+	case "TLSA":
+		return tlsaNormalize(v)
+	}
+	return v, nil
+}
+
+// httpDo makes a request to a provider API. The response body is parsed into
+// respBody if not nil, as XML for XML content types and JSON otherwise. For
+// non-2xx responses, an error with the status and part of the body is returned,
+// with notFound set for status 404.
+func httpDo(ctx context.Context, req *http.Request, respBody any) (notFound bool, rerr error) {
+	req = req.WithContext(ctx)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, 4*1024*1024))
+	if err != nil {
+		return false, fmt.Errorf("%w: reading response: %v", ErrProvider, err)
+	}
+	if resp.StatusCode/100 != 2 {
+		if len(buf) > 512 {
+			buf = buf[:512]
+		}
+		return resp.StatusCode == http.StatusNotFound, fmt.Errorf("%w: %s %s: status %s: %s", ErrProvider, req.Method, req.URL.Path, resp.Status, strings.TrimSpace(string(buf)))
+	}
+	if respBody == nil {
+		return false, nil
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/xml") || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/xml") {
+		err = xml.Unmarshal(buf, respBody)
+	} else {
+		err = json.Unmarshal(buf, respBody)
+	}
+	if err != nil {
+		return false, fmt.Errorf("%w: parsing response: %v", ErrProvider, err)
+	}
+	return false, nil
+}
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// newRequest returns a request with body, marshalled as JSON, or as XML if
+// already a []byte.
+func newRequest(method, url string, body any) (*http.Request, error) {
+	var r io.Reader
+	var ct string
+	switch b := body.(type) {
+	case nil:
+	case []byte:
+		r = bytes.NewReader(b)
+		ct = "application/xml"
+	default:
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("marshal request: %v", err)
+		}
+		r = bytes.NewReader(buf)
+		ct = "application/json"
+	}
+	req, err := http.NewRequest(method, url, r)
+	if err != nil {
+		return nil, err
+	}
+	if ct != "" {
+		req.Header.Set("Content-Type", ct)
+	}
+	req.Header.Set("User-Agent", "mox/"+moxvar.Version)
+	return req, nil
+}
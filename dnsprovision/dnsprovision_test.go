@@ -0,0 +1,463 @@
+package dnsprovision
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	gotbuf, _ := json.Marshal(got)
+	expbuf, _ := json.Marshal(exp)
+	if !bytes.Equal(gotbuf, expbuf) {
+		t.Fatalf("got:\n%s\nexpected:\n%s", gotbuf, expbuf)
+	}
+}
+
+// zone is a fake DNS zone for the fake providers, with records by name and type.
+type zone struct {
+	sync.Mutex
+	records map[string][]string // Key is "name type", values as with Provider.Records.
+}
+
+func (z *zone) get(name, typ string) []string {
+	z.Lock()
+	defer z.Unlock()
+	return slices.Clone(z.records[strings.ToLower(strings.TrimSuffix(name, "."))+" "+typ])
+}
+
+func (z *zone) set(name, typ string, values []string) {
+	z.Lock()
+	defer z.Unlock()
+	k := strings.ToLower(strings.TrimSuffix(name, ".")) + " " + typ
+	if len(values) == 0 {
+		delete(z.records, k)
+	} else {
+		z.records[k] = values
+	}
+}
+
+// testProvider checks adding, changing and removing records through provider p,
+// with the fake zone z behind it.
+func testProvider(t *testing.T, p Provider, z *zone) {
+	t.Helper()
+
+	zn := dns.Domain{ASCII: "example.org"}
+	dkimName := "sel._domainkey.example.org"
+	long := "v=DKIM1;h=sha256;p=" + strings.Repeat("A", 400)
+	tlsa := "3 1 1 " + strings.Repeat("ab", 32)
+
+	values, err := p.Records(ctxbg, zn, dkimName, "TXT")
+	tcheck(t, err, "records")
+	tcompare(t, len(values), 0)
+
+	err = p.SetRecords(ctxbg, zn, dkimName, "TXT", 300, []string{long})
+	tcheck(t, err, "set records")
+	values, err = p.Records(ctxbg, zn, dkimName, "TXT")
+	tcheck(t, err, "records")
+	tcompare(t, values, []string{long})
+	tcompare(t, z.get(dkimName, "TXT"), []string{long})
+
+	err = p.SetRecords(ctxbg, zn, "_25._tcp.mail.example.org", "TLSA", 300, []string{tlsa})
+	tcheck(t, err, "set records")
+	values, err = p.Records(ctxbg, zn, "_25._tcp.mail.example.org", "TLSA")
+	tcheck(t, err, "records")
+	tcompare(t, values, []string{tlsa})
+
+	// Sync only replaces SPF record, keeping other TXT records.
+	z.set("example.org", "TXT", []string{"v=spf1 -all", "other"})
+	records := []Record{
+		{Name: "example.org", Type: "TXT", Values: []string{"v=spf1 mx ~all"}, Prefix: "v=spf1 "},
+		{Name: dkimName, Type: "TXT", Values: []string{long}},
+	}
+	changed, err := Sync(ctxbg, pkglog, p, zn, 300, records)
+	tcheck(t, err, "sync")
+	tcompare(t, changed, []string{"TXT example.org"})
+	values = z.get("example.org", "TXT")
+	slices.Sort(values)
+	tcompare(t, values, []string{"other", "v=spf1 mx ~all"})
+	changed, err = Sync(ctxbg, pkglog, p, zn, 300, records)
+	tcheck(t, err, "sync")
+	tcompare(t, len(changed), 0)
+
+	err = p.SetRecords(ctxbg, zn, dkimName, "TXT", 300, nil)
+	tcheck(t, err, "remove records")
+	values, err = p.Records(ctxbg, zn, dkimName, "TXT")
+	tcheck(t, err, "records")
+	tcompare(t, len(values), 0)
+}
+
+func TestRFC2136(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+	keyName := "mox-key."
+	z := &zone{records: map[string][]string{}}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	tcheck(t, err, "listen")
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if err := serveRFC2136(conn, keyName, secret, z); err != nil {
+					t.Errorf("serve: %v", err)
+				}
+			}()
+		}
+	}()
+
+	p, err := New(config.DNSProvider{RFC2136: &config.DNSProviderRFC2136{
+		Server:      l.Addr().String(),
+		TSIGKeyName: "mox-key",
+		TSIGSecret:  base64.StdEncoding.EncodeToString(secret),
+	}})
+	tcheck(t, err, "new provider")
+	testProvider(t, p, z)
+
+	// Bad key is refused.
+	bad, err := newRFC2136(config.DNSProviderRFC2136{Server: l.Addr().String(), TSIGKeyName: "mox-key", TSIGSecret: base64.StdEncoding.EncodeToString([]byte("bad"))})
+	tcheck(t, err, "new provider")
+	_, err = bad.Records(ctxbg, dns.Domain{ASCII: "example.org"}, "example.org", "TXT")
+	if err == nil {
+		t.Fatalf("records with bad tsig key succeeded")
+	}
+}
+
+// serveRFC2136 handles a single query or update, like a primary name server.
+func serveRFC2136(conn net.Conn, keyName string, secret []byte, z *zone) error {
+	msg, err := readMessage(conn)
+	if err != nil {
+		return err
+	}
+	_, algorithm, _, reqMAC, _, err := tsigSplit(msg)
+	if err != nil {
+		return err
+	}
+	stripped, err := tsigVerify(keyName, secret, msg, nil, time.Now())
+	rcode := dnsmessage.RCodeSuccess
+	if err != nil {
+		rcode = dnsmessage.RCodeRefused
+	}
+
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return err
+	}
+	q, err := p.Question()
+	if err != nil {
+		return err
+	}
+	if rcode == dnsmessage.RCodeSuccess {
+		p.Start(stripped)
+		p.SkipAllQuestions()
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true, OpCode: h.OpCode, RCode: rcode})
+	b.StartQuestions()
+	b.Question(q)
+	if rcode == dnsmessage.RCodeSuccess && h.OpCode == opcodeUpdate {
+		p.SkipAllAnswers()
+		var name, typ string
+		var values []string
+		for {
+			ah, err := p.AuthorityHeader()
+			if err == dnsmessage.ErrSectionDone {
+				break
+			} else if err != nil {
+				return err
+			}
+			name = ah.Name.String()
+			if ah.Class == dnsmessage.ClassANY {
+				typ = strings.TrimPrefix(ah.Type.String(), "Type")
+				if ah.Type == typeTLSA {
+					typ = "TLSA"
+				}
+				p.SkipAuthority()
+				continue
+			}
+			switch ah.Type {
+			case dnsmessage.TypeTXT:
+				r, err := p.TXTResource()
+				if err != nil {
+					return err
+				}
+				values = append(values, strings.Join(r.TXT, ""))
+			case typeTLSA:
+				r, err := p.UnknownResource()
+				if err != nil {
+					return err
+				}
+				values = append(values, fmt.Sprintf("%d %d %d %x", r.Data[0], r.Data[1], r.Data[2], r.Data[3:]))
+			}
+		}
+		z.set(name, typ, values)
+	} else if rcode == dnsmessage.RCodeSuccess {
+		b.StartAnswers()
+		rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 300}
+		typ := "TXT"
+		if q.Type == typeTLSA {
+			typ = "TLSA"
+		}
+		for _, v := range z.get(q.Name.String(), typ) {
+			if typ == "TXT" {
+				b.TXTResource(rh, dnsmessage.TXTResource{TXT: txtStrings(v)})
+			} else {
+				data, _ := tlsaData(v)
+				b.UnknownResource(rh, dnsmessage.UnknownResource{Type: typeTLSA, Data: data})
+			}
+		}
+	}
+	resp, err := b.Finish()
+	if err != nil {
+		return err
+	}
+	if rcode == dnsmessage.RCodeSuccess {
+		resp, _, err = tsigSign(keyName, algorithm, secret, resp, reqMAC, time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(resp)))
+	_, err = conn.Write(append(buf, resp...))
+	return err
+}
+
+func TestCloudflare(t *testing.T) {
+	z := &zone{records: map[string][]string{}}
+
+	// Record IDs are the name, type and value.
+	makeID := func(name, typ, value string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(name + "\n" + typ + "\n" + value))
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var result any
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/zones":
+			result = []map[string]string{{"id": "z1"}}
+		case r.Method == "GET" && r.URL.Path == "/zones/z1/dns_records":
+			l := []map[string]string{}
+			name, typ := r.FormValue("name"), r.FormValue("type")
+			for _, v := range z.get(name, typ) {
+				content := v
+				if typ == "TXT" {
+					content = txtQuote(v)
+				} else if typ == "TLSA" {
+					// Cloudflare returns upper case hex.
+					content = strings.ToUpper(v)
+				}
+				l = append(l, map[string]string{"id": makeID(name, typ, v), "content": content})
+			}
+			result = l
+		case r.Method == "POST" && r.URL.Path == "/zones/z1/dns_records":
+			var rec struct {
+				Type    string
+				Name    string
+				Content string
+				Data    struct {
+					Usage        int
+					Selector     int
+					MatchingType int `json:"matching_type"`
+					Certificate  string
+				}
+			}
+			err := json.NewDecoder(r.Body).Decode(&rec)
+			tcheck(t, err, "parse record")
+			v := rec.Content
+			if rec.Type == "TLSA" {
+				v = fmt.Sprintf("%d %d %d %s", rec.Data.Usage, rec.Data.Selector, rec.Data.MatchingType, rec.Data.Certificate)
+			}
+			v, err = normalizeValue(rec.Type, v)
+			tcheck(t, err, "normalize value")
+			z.set(rec.Name, rec.Type, append(z.get(rec.Name, rec.Type), v))
+		case r.Method == "DELETE" && strings.HasPrefix(r.URL.Path, "/zones/z1/dns_records/"):
+			buf, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(r.URL.Path, "/zones/z1/dns_records/"))
+			tcheck(t, err, "parse id")
+			t := strings.Split(string(buf), "\n")
+			values := z.get(t[0], t[1])
+			z.set(t[0], t[1], slices.DeleteFunc(values, func(v string) bool { return v == t[2] }))
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "result": result})
+	}))
+	defer srv.Close()
+
+	p, err := New(config.DNSProvider{Cloudflare: &config.DNSProviderToken{APIToken: "token", BaseURL: srv.URL}})
+	tcheck(t, err, "new provider")
+	testProvider(t, p, z)
+}
+
+func TestDeSEC(t *testing.T) {
+	z := &zone{records: map[string][]string{}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Token token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		const prefix = "/domains/example.org/rrsets/"
+		if !strings.HasPrefix(r.URL.Path, prefix) {
+			http.NotFound(w, r)
+			return
+		}
+		fqdn := func(sub string) string {
+			if sub == "" || sub == "@" {
+				return "example.org"
+			}
+			return sub + ".example.org"
+		}
+		switch r.Method {
+		case "GET":
+			t := strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")
+			values := z.get(fqdn(t[0]), t[1])
+			if len(values) == 0 {
+				http.NotFound(w, r)
+				return
+			}
+			rrset := desecRRset{Subname: t[0], Type: t[1], TTL: 3600}
+			for _, v := range values {
+				if t[1] == "TXT" {
+					v = txtQuote(v)
+				}
+				rrset.Records = append(rrset.Records, v)
+			}
+			json.NewEncoder(w).Encode(rrset)
+		case "PUT":
+			var l []desecRRset
+			err := json.NewDecoder(r.Body).Decode(&l)
+			tcheck(t, err, "parse rrsets")
+			for _, rrset := range l {
+				if rrset.TTL < desecMinTTL {
+					http.Error(w, "ttl too low", http.StatusBadRequest)
+					return
+				}
+				var values []string
+				for _, v := range rrset.Records {
+					v, err := normalizeValue(rrset.Type, v)
+					tcheck(t, err, "normalize value")
+					values = append(values, v)
+				}
+				z.set(fqdn(rrset.Subname), rrset.Type, values)
+			}
+		}
+	}))
+	defer srv.Close()
+
+	p, err := New(config.DNSProvider{DeSEC: &config.DNSProviderToken{APIToken: "token", BaseURL: srv.URL}})
+	tcheck(t, err, "new provider")
+	testProvider(t, p, z)
+}
+
+func TestRoute53(t *testing.T) {
+	z := &zone{records: map[string][]string{}}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKID/") || r.Header.Get("X-Amz-Date") == "" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		if r.URL.Path != "/2013-04-01/hostedzone/Z1/rrset" && r.URL.Path != "/2013-04-01/hostedzone/Z1/rrset/" {
+			http.NotFound(w, r)
+			return
+		}
+		switch r.Method {
+		case "GET":
+			var l route53List
+			name := strings.TrimSuffix(r.FormValue("name"), ".")
+			if values := z.get(name, r.FormValue("type")); len(values) > 0 {
+				rrset := route53RRset{Name: name + ".", Type: r.FormValue("type"), TTL: 300}
+				for _, v := range values {
+					if rrset.Type == "TXT" {
+						v = txtQuote(v)
+					}
+					rrset.ResourceRecords = append(rrset.ResourceRecords, route53Value{v})
+				}
+				l.ResourceRecordSets = []route53RRset{rrset}
+			}
+			w.Header().Set("Content-Type", "text/xml")
+			xml.NewEncoder(w).Encode(l)
+		case "POST":
+			buf, err := io.ReadAll(r.Body)
+			tcheck(t, err, "read body")
+			var req route53ChangeRequest
+			err = xml.Unmarshal(buf, &req)
+			tcheck(t, err, "parse change request")
+			for _, c := range req.Changes {
+				rrset := c.ResourceRecordSet
+				if c.Action == "DELETE" {
+					z.set(rrset.Name, rrset.Type, nil)
+					continue
+				}
+				var values []string
+				for _, v := range rrset.ResourceRecords {
+					v, err := normalizeValue(rrset.Type, v.Value)
+					tcheck(t, err, "normalize value")
+					values = append(values, v)
+				}
+				z.set(rrset.Name, rrset.Type, values)
+			}
+			w.Header().Set("Content-Type", "text/xml")
+			fmt.Fprint(w, `<ChangeResourceRecordSetsResponse/>`)
+		}
+	}))
+	defer srv.Close()
+
+	p, err := New(config.DNSProvider{Route53: &config.DNSProviderRoute53{AccessKeyID: "AKID", SecretAccessKey: "secret", HostedZoneID: "Z1", BaseURL: srv.URL}})
+	tcheck(t, err, "new provider")
+	testProvider(t, p, z)
+}
+
+func TestTXT(t *testing.T) {
+	s := `a"b\c` + strings.Repeat("x", 300)
+	q := txtQuote(s)
+	tcompare(t, strings.Count(q, `" "`), 1)
+	u, err := txtUnquote(q)
+	tcheck(t, err, "unquote")
+	tcompare(t, u, s)
+	u, err = txtUnquote("unquoted")
+	tcheck(t, err, "unquote")
+	tcompare(t, u, "unquoted")
+	_, err = txtUnquote(`"unterminated`)
+	if err == nil {
+		t.Fatalf("unquote of unterminated string succeeded")
+	}
+}
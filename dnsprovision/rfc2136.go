@@ -0,0 +1,499 @@
+package dnsprovision
+
+import (
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+)
+
+// ../rfc/2136:237
+const opcodeUpdate dnsmessage.OpCode = 5
+
+// ../rfc/8945:399
+const typeTSIG dnsmessage.Type = 250
+
+const typeTLSA dnsmessage.Type = 52
+
+// tsigFudge is the allowed time difference for signed messages, in seconds.
+// ../rfc/8945:1010
+const tsigFudge = 300
+
+// rfc2136 sends DNS UPDATE messages over TCP, signed with TSIG. Records are read
+// with queries to the same server.
+type rfc2136 struct {
+	server    string
+	keyName   string // Lower case, with trailing dot.
+	algorithm string // With trailing dot.
+	secret    []byte
+}
+
+func newRFC2136(c config.DNSProviderRFC2136) (*rfc2136, error) {
+	secret, err := base64.StdEncoding.DecodeString(c.TSIGSecret)
+	if err != nil {
+		return nil, fmt.Errorf("parsing tsig secret: %v", err)
+	}
+	alg := c.TSIGAlgorithm
+	if alg == "" {
+		alg = "hmac-sha256"
+	}
+	return &rfc2136{
+		server:    c.Server,
+		keyName:   strings.ToLower(strings.TrimSuffix(c.TSIGKeyName, ".")) + ".",
+		algorithm: alg + ".",
+		secret:    secret,
+	}, nil
+}
+
+func (p *rfc2136) Records(ctx context.Context, zone dns.Domain, name, typ string) ([]string, error) {
+	t, err := rrType(typ)
+	if err != nil {
+		return nil, err
+	}
+	qname, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return nil, fmt.Errorf("parsing name: %v", err)
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: messageID()})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: qname, Type: t, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return nil, fmt.Errorf("making query: %v", err)
+	}
+
+	resp, err := p.exchange(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+
+	var pr dnsmessage.Parser
+	h, err := pr.Start(resp)
+	if err != nil {
+		return nil, fmt.Errorf("%w: parsing response: %v", ErrProvider, err)
+	}
+	if h.RCode == dnsmessage.RCodeNameError {
+		return nil, nil
+	} else if h.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("%w: query failed: %s", ErrProvider, h.RCode)
+	}
+	if err := pr.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("%w: parsing response: %v", ErrProvider, err)
+	}
+	var values []string
+	for {
+		ah, err := pr.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%w: parsing response: %v", ErrProvider, err)
+		}
+		if ah.Type != t || !strings.EqualFold(ah.Name.String(), qname.String()) {
+			if err := pr.SkipAnswer(); err != nil {
+				return nil, fmt.Errorf("%w: parsing response: %v", ErrProvider, err)
+			}
+			continue
+		}
+		switch t {
+		case dnsmessage.TypeTXT:
+			r, err := pr.TXTResource()
+			if err != nil {
+				return nil, fmt.Errorf("%w: parsing txt record: %v", ErrProvider, err)
+			}
+			values = append(values, strings.Join(r.TXT, ""))
+		case typeTLSA:
+			r, err := pr.UnknownResource()
+			if err != nil {
+				return nil, fmt.Errorf("%w: parsing tlsa record: %v", ErrProvider, err)
+			}
+			if len(r.Data) < 3 {
+				return nil, fmt.Errorf("%w: short tlsa record", ErrProvider)
+			}
+			values = append(values, fmt.Sprintf("%d %d %d %x", r.Data[0], r.Data[1], r.Data[2], r.Data[3:]))
+		}
+	}
+	return values, nil
+}
+
+func (p *rfc2136) SetRecords(ctx context.Context, zone dns.Domain, name, typ string, ttl int, values []string) error {
+	t, err := rrType(typ)
+	if err != nil {
+		return err
+	}
+	zname, err := dnsmessage.NewName(zone.ASCII + ".")
+	if err != nil {
+		return fmt.Errorf("parsing zone: %v", err)
+	}
+	rname, err := dnsmessage.NewName(name + ".")
+	if err != nil {
+		return fmt.Errorf("parsing name: %v", err)
+	}
+
+	// The zone section is in the place of the question section, prerequisites in the
+	// answer section and updates in the authority section. ../rfc/2136:263
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: messageID(), OpCode: opcodeUpdate})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return err
+	}
+	if err := b.Question(dnsmessage.Question{Name: zname, Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET}); err != nil {
+		return err
+	}
+	if err := b.StartAuthorities(); err != nil {
+		return err
+	}
+	// Delete the RRset, class ANY, ttl 0 and no data. ../rfc/2136:766
+	if err := b.UnknownResource(dnsmessage.ResourceHeader{Name: rname, Class: dnsmessage.ClassANY}, dnsmessage.UnknownResource{Type: t}); err != nil {
+		return fmt.Errorf("adding delete: %v", err)
+	}
+	// Add the new records. ../rfc/2136:750
+	rh := dnsmessage.ResourceHeader{Name: rname, Class: dnsmessage.ClassINET, TTL: uint32(ttl)}
+	for _, v := range values {
+		switch t {
+		case dnsmessage.TypeTXT:
+			err = b.TXTResource(rh, dnsmessage.TXTResource{TXT: txtStrings(v)})
+		case typeTLSA:
+			var data []byte
+			data, err = tlsaData(v)
+			if err == nil {
+				err = b.UnknownResource(rh, dnsmessage.UnknownResource{Type: typeTLSA, Data: data})
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("adding record: %v", err)
+		}
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		return fmt.Errorf("making update: %v", err)
+	}
+
+	resp, err := p.exchange(ctx, msg)
+	if err != nil {
+		return err
+	}
+	var pr dnsmessage.Parser
+	h, err := pr.Start(resp)
+	if err != nil {
+		return fmt.Errorf("%w: parsing response: %v", ErrProvider, err)
+	}
+	if h.RCode != dnsmessage.RCodeSuccess {
+		return fmt.Errorf("%w: update failed: %s", ErrProvider, h.RCode)
+	}
+	return nil
+}
+
+// exchange signs msg with TSIG, sends it to the server and returns the response
+// after verifying its signature, with the TSIG record removed.
+func (p *rfc2136) exchange(ctx context.Context, msg []byte) ([]byte, error) {
+	msg, mac, err := tsigSign(p.keyName, p.algorithm, p.secret, msg, nil, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	d := net.Dialer{Timeout: 30 * time.Second}
+	conn, err := d.DialContext(ctx, "tcp", p.server)
+	if err != nil {
+		return nil, fmt.Errorf("%w: dial: %v", ErrProvider, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(30 * time.Second))
+	}
+
+	// TCP messages have a 2-byte length prefix. ../rfc/1035:2215
+	buf := binary.BigEndian.AppendUint16(nil, uint16(len(msg)))
+	if _, err := conn.Write(append(buf, msg...)); err != nil {
+		return nil, fmt.Errorf("%w: write: %v", ErrProvider, err)
+	}
+	resp, err := readMessage(conn)
+	if err != nil {
+		return nil, fmt.Errorf("%w: read: %v", ErrProvider, err)
+	}
+	if len(resp) < 12 || resp[0] != msg[0] || resp[1] != msg[1] {
+		return nil, fmt.Errorf("%w: response does not match request", ErrProvider)
+	}
+	return tsigVerify(p.keyName, p.secret, resp, mac, time.Now())
+}
+
+func readMessage(r io.Reader) ([]byte, error) {
+	var buf [2]byte
+	if _, err := io.ReadFull(r, buf[:]); err != nil {
+		return nil, err
+	}
+	msg := make([]byte, binary.BigEndian.Uint16(buf[:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+func messageID() uint16 {
+	var buf [2]byte
+	cryptorand.Read(buf[:])
+	return binary.BigEndian.Uint16(buf[:])
+}
+
+func rrType(typ string) (dnsmessage.Type, error) {
+	switch typ {
+	case "TXT":
+		return dnsmessage.TypeTXT, nil
+	case "TLSA":
+		return typeTLSA, nil
+	}
+	return 0, fmt.Errorf("unsupported record type %q", typ)
+}
+
+// tlsaData returns the wire format data for a TLSA record in presentation format.
+func tlsaData(v string) ([]byte, error) {
+	t := strings.Fields(v)
+	if len(t) < 4 {
+		return nil, fmt.Errorf("bad tlsa record %q", v)
+	}
+	var data []byte
+	for _, s := range t[:3] {
+		n, err := strconv.ParseUint(s, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("bad tlsa record %q: %v", v, err)
+		}
+		data = append(data, byte(n))
+	}
+	assoc, err := hex.DecodeString(strings.Join(t[3:], ""))
+	if err != nil {
+		return nil, fmt.Errorf("bad tlsa record %q: %v", v, err)
+	}
+	return append(data, assoc...), nil
+}
+
+// wireName returns a name in uncompressed canonical wire format. ../rfc/8945:637
+func wireName(name string) []byte {
+	var buf []byte
+	for _, l := range strings.Split(strings.TrimSuffix(strings.ToLower(name), "."), ".") {
+		buf = append(buf, byte(len(l)))
+		buf = append(buf, l...)
+	}
+	return append(buf, 0)
+}
+
+func tsigHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "hmac-sha256.":
+		return sha256.New, nil
+	case "hmac-sha512.":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("unsupported tsig algorithm %q", algorithm)
+}
+
+// tsigMAC computes the MAC for a message without TSIG record. For responses,
+// requestMAC is the MAC of the request. ../rfc/8945:580
+func tsigMAC(keyName, algorithm string, secret, msg, requestMAC []byte, timeSigned uint64, origID, tsigError uint16) ([]byte, error) {
+	hashFn, err := tsigHash(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(hashFn, secret)
+	if requestMAC != nil {
+		mac.Write(binary.BigEndian.AppendUint16(nil, uint16(len(requestMAC))))
+		mac.Write(requestMAC)
+	}
+	// MAC is computed with the original message ID. ../rfc/8945:609
+	m := append([]byte{}, msg...)
+	binary.BigEndian.PutUint16(m[0:2], origID)
+	mac.Write(m)
+
+	var v []byte
+	v = append(v, wireName(keyName)...)
+	v = binary.BigEndian.AppendUint16(v, uint16(dnsmessage.ClassANY))
+	v = binary.BigEndian.AppendUint32(v, 0) // TTL
+	v = append(v, wireName(algorithm)...)
+	v = append(v, byte(timeSigned>>40), byte(timeSigned>>32), byte(timeSigned>>24), byte(timeSigned>>16), byte(timeSigned>>8), byte(timeSigned))
+	v = binary.BigEndian.AppendUint16(v, tsigFudge)
+	v = binary.BigEndian.AppendUint16(v, tsigError)
+	v = binary.BigEndian.AppendUint16(v, 0) // Other len.
+	mac.Write(v)
+	return mac.Sum(nil), nil
+}
+
+// tsigSign appends a TSIG record to msg, returning the signed message and the
+// MAC. For responses, requestMAC is the MAC of the request. ../rfc/8945:399
+func tsigSign(keyName, algorithm string, secret, msg, requestMAC []byte, now time.Time) (signed, mac []byte, rerr error) {
+	if len(msg) < 12 {
+		return nil, nil, fmt.Errorf("message too short")
+	}
+	timeSigned := uint64(now.Unix())
+	origID := binary.BigEndian.Uint16(msg[0:2])
+	mac, err := tsigMAC(keyName, algorithm, secret, msg, requestMAC, timeSigned, origID, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var rdata []byte
+	rdata = append(rdata, wireName(algorithm)...)
+	rdata = append(rdata, byte(timeSigned>>40), byte(timeSigned>>32), byte(timeSigned>>24), byte(timeSigned>>16), byte(timeSigned>>8), byte(timeSigned))
+	rdata = binary.BigEndian.AppendUint16(rdata, tsigFudge)
+	rdata = binary.BigEndian.AppendUint16(rdata, uint16(len(mac)))
+	rdata = append(rdata, mac...)
+	rdata = binary.BigEndian.AppendUint16(rdata, origID)
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // Error.
+	rdata = binary.BigEndian.AppendUint16(rdata, 0) // Other len.
+
+	signed = append([]byte{}, msg...)
+	signed = append(signed, wireName(keyName)...)
+	signed = binary.BigEndian.AppendUint16(signed, uint16(typeTSIG))
+	signed = binary.BigEndian.AppendUint16(signed, uint16(dnsmessage.ClassANY))
+	signed = binary.BigEndian.AppendUint32(signed, 0)
+	signed = binary.BigEndian.AppendUint16(signed, uint16(len(rdata)))
+	signed = append(signed, rdata...)
+	// Increase additional count.
+	binary.BigEndian.PutUint16(signed[10:12], binary.BigEndian.Uint16(signed[10:12])+1)
+	return signed, mac, nil
+}
+
+// tsigVerify verifies the TSIG record at the end of msg, returning msg without the
+// TSIG record and the MAC. For responses, requestMAC is the MAC of the request.
+func tsigVerify(keyName string, secret, msg, requestMAC []byte, now time.Time) ([]byte, error) {
+	stripped, algorithm, timeSigned, mac, tsigError, err := tsigSplit(msg)
+	if err != nil {
+		return nil, err
+	}
+	if tsigError != 0 {
+		// ../rfc/8945:1095
+		return nil, fmt.Errorf("%w: tsig error %d", ErrProvider, tsigError)
+	}
+	origID := binary.BigEndian.Uint16(stripped[0:2])
+	exp, err := tsigMAC(keyName, algorithm, secret, stripped, requestMAC, timeSigned, origID, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, exp) {
+		return nil, fmt.Errorf("%w: bad tsig signature", ErrProvider)
+	}
+	if d := now.Unix() - int64(timeSigned); d > tsigFudge || d < -tsigFudge {
+		return nil, fmt.Errorf("%w: tsig time outside of allowed range", ErrProvider)
+	}
+	return stripped, nil
+}
+
+// tsigSplit finds the TSIG record as last record of the additional section, and
+// returns the message without it, with its original ID, and fields of the TSIG
+// record.
+func tsigSplit(msg []byte) (stripped []byte, algorithm string, timeSigned uint64, mac []byte, tsigError uint16, rerr error) {
+	bad := func(format string, args ...any) ([]byte, string, uint64, []byte, uint16, error) {
+		return nil, "", 0, nil, 0, fmt.Errorf("%w: %s", ErrProvider, fmt.Sprintf(format, args...))
+	}
+
+	if len(msg) < 12 {
+		return bad("message too short")
+	}
+	qd := int(binary.BigEndian.Uint16(msg[4:6]))
+	an := int(binary.BigEndian.Uint16(msg[6:8]))
+	ns := int(binary.BigEndian.Uint16(msg[8:10]))
+	ar := int(binary.BigEndian.Uint16(msg[10:12]))
+	if ar == 0 {
+		return bad("message not signed with tsig")
+	}
+
+	// Skip over a name, possibly compressed.
+	skipName := func(o int) int {
+		for o < len(msg) {
+			n := int(msg[o])
+			if n == 0 {
+				return o + 1
+			} else if n&0xc0 == 0xc0 {
+				return o + 2
+			}
+			o += 1 + n
+		}
+		return -1
+	}
+	o := 12
+	for range qd {
+		if o = skipName(o); o < 0 || o+4 > len(msg) {
+			return bad("malformed message")
+		}
+		o += 4
+	}
+	for range an + ns + ar - 1 {
+		if o = skipName(o); o < 0 || o+10 > len(msg) {
+			return bad("malformed message")
+		}
+		o += 10 + int(binary.BigEndian.Uint16(msg[o+8:o+10]))
+	}
+	tsigOff := o
+	if o = skipName(o); o < 0 || o+10 > len(msg) {
+		return bad("malformed message")
+	}
+	if dnsmessage.Type(binary.BigEndian.Uint16(msg[o:o+2])) != typeTSIG {
+		return bad("message not signed with tsig")
+	}
+	rdlen := int(binary.BigEndian.Uint16(msg[o+8 : o+10]))
+	rdata := msg[o+10:]
+	if len(rdata) != rdlen {
+		return bad("malformed tsig record")
+	}
+
+	// Algorithm name, uncompressed.
+	var labels []string
+	i := 0
+	for {
+		if i >= len(rdata) {
+			return bad("malformed tsig record")
+		}
+		n := int(rdata[i])
+		i++
+		if n == 0 {
+			break
+		} else if n&0xc0 != 0 || i+n > len(rdata) {
+			return bad("malformed tsig algorithm")
+		}
+		labels = append(labels, string(rdata[i:i+n]))
+		i += n
+	}
+	algorithm = strings.Join(labels, ".") + "."
+	if i+10 > len(rdata) {
+		return bad("malformed tsig record")
+	}
+	for _, b := range rdata[i : i+6] {
+		timeSigned = timeSigned<<8 | uint64(b)
+	}
+	i += 8 // Including fudge.
+	macSize := int(binary.BigEndian.Uint16(rdata[i : i+2]))
+	i += 2
+	if i+macSize+6 > len(rdata) {
+		return bad("malformed tsig record")
+	}
+	mac = rdata[i : i+macSize]
+	i += macSize
+	origID := binary.BigEndian.Uint16(rdata[i : i+2])
+	tsigError = binary.BigEndian.Uint16(rdata[i+2 : i+4])
+
+	stripped = append([]byte{}, msg[:tsigOff]...)
+	binary.BigEndian.PutUint16(stripped[0:2], origID)
+	binary.BigEndian.PutUint16(stripped[10:12], uint16(ar-1))
+	return stripped, algorithm, timeSigned, mac, tsigError, nil
+}
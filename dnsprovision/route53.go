@@ -0,0 +1,188 @@
+package dnsprovision
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+)
+
+// route53 uses the Amazon Route 53 REST API, with requests signed with AWS
+// signature version 4.
+type route53 struct {
+	accessKeyID     string
+	secretAccessKey string
+	hostedZoneID    string
+	baseURL         string
+}
+
+func newRoute53(c config.DNSProviderRoute53) *route53 {
+	baseURL := c.BaseURL
+	if baseURL == "" {
+		baseURL = "https://route53.amazonaws.com"
+	}
+	return &route53{
+		accessKeyID:     c.AccessKeyID,
+		secretAccessKey: c.SecretAccessKey,
+		hostedZoneID:    strings.TrimPrefix(c.HostedZoneID, "/hostedzone/"),
+		baseURL:         strings.TrimSuffix(baseURL, "/"),
+	}
+}
+
+const route53XMLNS = "https://route53.amazonaws.com/doc/2013-04-01/"
+
+type route53RRset struct {
+	Name            string
+	Type            string
+	TTL             int
+	ResourceRecords []route53Value `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53Value struct {
+	Value string
+}
+
+type route53List struct {
+	XMLName            xml.Name       `xml:"ListResourceRecordSetsResponse"`
+	ResourceRecordSets []route53RRset `xml:"ResourceRecordSets>ResourceRecordSet"`
+}
+
+type route53Change struct {
+	Action            string
+	ResourceRecordSet route53RRset
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	XMLNS   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+// get returns the RRset for name and type, or nil if it doesn't exist.
+func (p *route53) get(ctx context.Context, name, typ string) (*route53RRset, error) {
+	q := url.Values{"name": {name + "."}, "type": {typ}, "maxitems": {"1"}}
+	req, err := newRequest("GET", p.baseURL+"/2013-04-01/hostedzone/"+url.PathEscape(p.hostedZoneID)+"/rrset?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	p.sign(req, nil, time.Now())
+	var l route53List
+	if _, err := httpDo(ctx, req, &l); err != nil {
+		return nil, err
+	}
+	// The list starts at name and type, it can contain a later RRset.
+	if len(l.ResourceRecordSets) == 0 || !strings.EqualFold(l.ResourceRecordSets[0].Name, name+".") || l.ResourceRecordSets[0].Type != typ {
+		return nil, nil
+	}
+	return &l.ResourceRecordSets[0], nil
+}
+
+func (p *route53) Records(ctx context.Context, zone dns.Domain, name, typ string) ([]string, error) {
+	rrset, err := p.get(ctx, name, typ)
+	if err != nil || rrset == nil {
+		return nil, err
+	}
+	var values []string
+	for _, r := range rrset.ResourceRecords {
+		v, err := normalizeValue(typ, r.Value)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (p *route53) SetRecords(ctx context.Context, zone dns.Domain, name, typ string, ttl int, values []string) error {
+	var change route53Change
+	if len(values) == 0 {
+		// Deleting requires the exact current RRset.
+		rrset, err := p.get(ctx, name, typ)
+		if err != nil || rrset == nil {
+			return err
+		}
+		change = route53Change{Action: "DELETE", ResourceRecordSet: *rrset}
+	} else {
+		rrset := route53RRset{Name: name + ".", Type: typ, TTL: ttl}
+		for _, v := range values {
+			if typ == "TXT" {
+				v = txtQuote(v)
+			}
+			rrset.ResourceRecords = append(rrset.ResourceRecords, route53Value{v})
+		}
+		change = route53Change{Action: "UPSERT", ResourceRecordSet: rrset}
+	}
+
+	buf, err := xml.Marshal(route53ChangeRequest{XMLNS: route53XMLNS, Changes: []route53Change{change}})
+	if err != nil {
+		return fmt.Errorf("marshal request: %v", err)
+	}
+	buf = append([]byte(xml.Header), buf...)
+	req, err := newRequest("POST", p.baseURL+"/2013-04-01/hostedzone/"+url.PathEscape(p.hostedZoneID)+"/rrset/", buf)
+	if err != nil {
+		return err
+	}
+	p.sign(req, buf, time.Now())
+	_, err = httpDo(ctx, req, nil)
+	return err
+}
+
+// sign adds an AWS signature version 4 authorization header to the request.
+// Route 53 is a global service, signed for region us-east-1.
+func (p *route53) sign(req *http.Request, body []byte, now time.Time) {
+	const region = "us-east-1"
+	const service = "route53"
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	sum := func(b []byte) string {
+		h := sha256.Sum256(b)
+		return hex.EncodeToString(h[:])
+	}
+	mac := func(key []byte, s string) []byte {
+		m := hmac.New(sha256.New, key)
+		m.Write([]byte(s))
+		return m.Sum(nil)
+	}
+
+	// Canonical query string, with keys sorted and spaces encoded as %20.
+	q := req.URL.Query()
+	var params []string
+	for k, vl := range q {
+		for _, v := range vl {
+			params = append(params, strings.ReplaceAll(url.QueryEscape(k), "+", "%20")+"="+strings.ReplaceAll(url.QueryEscape(v), "+", "%20"))
+		}
+	}
+	slices.Sort(params)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(params, "&"),
+		"host:" + req.URL.Host + "\nx-amz-date:" + amzDate + "\n",
+		"host;x-amz-date",
+		sum(body),
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sum([]byte(canonicalRequest))
+
+	key := mac([]byte("AWS4"+p.secretAccessKey), date)
+	key = mac(key, region)
+	key = mac(key, service)
+	key = mac(key, "aws4_request")
+	signature := hex.EncodeToString(mac(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=host;x-amz-date, Signature=%s", p.accessKeyID, scope, signature))
+}
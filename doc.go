@@ -25,9 +25,14 @@ any parameters. Followed by the help and usage information for each command.
 	mox setaccountpassword account
 	mox setadminpassword
 	mox loglevels [level [pkg]]
+	mox auditlog export [-format json|syslog] [-kind kind] [-account account] [-since duration]
+	mox provisioning token list
+	mox provisioning token add name
+	mox provisioning token rm name
 	mox queue holdrules list
 	mox queue holdrules add [ruleflags]
 	mox queue holdrules remove ruleid
+	mox queue holdrules resume ruleid
 	mox queue list [filtersortflags]
 	mox queue hold [filterflags]
 	mox queue unhold [filterflags]
@@ -39,6 +44,12 @@ any parameters. Followed by the help and usage information for each command.
 	mox queue dump id
 	mox queue retired list [filtersortflags]
 	mox queue retired print id
+	mox queue dead list [filterflags]
+	mox queue dead print id
+	mox queue dead dump id
+	mox queue dead recipient id address
+	mox queue dead requeue [filterflags]
+	mox queue dead remove [filterflags]
 	mox queue suppress list [-account account]
 	mox queue suppress add account address
 	mox queue suppress remove account address
@@ -51,8 +62,10 @@ any parameters. Followed by the help and usage information for each command.
 	mox queue webhook retired print id
 	mox import maildir accountname mailboxname maildir
 	mox import mbox accountname mailboxname mbox
-	mox export maildir [-single] dst-dir account-path [mailbox]
+	mox export maildir [-single] [-dovecot] dst-dir account-path [mailbox]
 	mox export mbox [-single] dst-dir account-path [mailbox]
+	mox junk export account >spamdata.json
+	mox junk import account <spamdata.json
 	mox localserve
 	mox help [command ...]
 	mox backup destdir
@@ -61,13 +74,20 @@ any parameters. Followed by the help and usage information for each command.
 	mox config test
 	mox config dnscheck domain
 	mox config dnsrecords domain
+	mox config dnsprovision domain
 	mox config describe-domains >domains.conf
 	mox config describe-static >mox.conf
 	mox config account list
 	mox config account add account address
+	mox config account import [-dryrun] [-requirechange] file.csv|file.json
 	mox config account rm account
 	mox config account disable account message
 	mox config account enable account
+	mox config adminuser list
+	mox config adminuser add [-domains domain1,...] name admin|domainadmin|auditor
+	mox config adminuser update [-domains domain1,...] name admin|domainadmin|auditor
+	mox config adminuser setpassword name
+	mox config adminuser rm name
 	mox config address add address account
 	mox config address rm address
 	mox config domain add [-disabled] domain account [localpart]
@@ -79,6 +99,7 @@ any parameters. Followed by the help and usage information for each command.
 	mox config tlspubkey add address [name] < cert.pem
 	mox config tlspubkey rm fingerprint
 	mox config tlspubkey gen stem
+	mox config tlscerts reload
 	mox config alias list domain
 	mox config alias print alias
 	mox config alias add alias@domain rcpt1@domain ...
@@ -224,6 +245,10 @@ Set a new admin password, for the web interface.
 The password is read from stdin. Its bcrypt hash is stored in a file named
 "adminpasswd" in the configuration directory.
 
+If two-factor authentication is enabled for admin logins, its secret is stored
+in "adminpasswd.totp". Remove that file to disable it, e.g. after losing the
+authenticator app.
+
 	usage: mox setadminpassword
 
 # mox loglevels
@@ -241,6 +266,54 @@ Valid labels: error, info, debug, trace, traceauth, tracedata.
 
 	usage: mox loglevels [level [pkg]]
 
+# mox auditlog export
+
+Export events from the audit log, oldest first.
+
+The audit log records security-relevant events: logins and failed logins,
+password and settings changes, configuration changes by admins, accounts
+added and removed, exports and queue changes.
+
+With format json (default), each event is printed as a JSON object on a line.
+With format syslog, each event is printed as a syslog message as in RFC 5424,
+with facility authpriv, for feeding to a log collector.
+
+Kinds: login, password, settings, config, account, export, queue.
+
+	usage: mox auditlog export [-format json|syslog] [-kind kind] [-account account] [-since duration]
+	  -account string
+	    	only export events for this account
+	  -format string
+	    	json or syslog (default "json")
+	  -kind string
+	    	only export events of this kind
+	  -since string
+	    	only export events in this period before now, e.g. 24h
+
+# mox provisioning token list
+
+List tokens for the provisioning API.
+
+Each token is printed on a line, with tab-separated creation and last use time.
+
+	usage: mox provisioning token list
+
+# mox provisioning token add
+
+Add a token for the provisioning API, and print it.
+
+The provisioning API must be enabled in mox.conf. Requests must have the token
+in an Authorization header: "Bearer <token>". Only a hash of the token is
+stored, it cannot be retrieved later.
+
+	usage: mox provisioning token add name
+
+# mox provisioning token rm
+
+Remove a token for the provisioning API.
+
+	usage: mox provisioning token rm name
+
 # mox queue holdrules list
 
 List hold rules for the delivery queue.
@@ -274,6 +347,18 @@ Remove a hold rule by its id.
 
 	usage: mox queue holdrules remove ruleid
 
+# mox queue holdrules resume
+
+Remove hold rule and resume delivery of matching messages.
+
+Remove a hold rule by its id, like "queue holdrules remove", and additionally
+take messages in the queue that match the rule off hold. Messages that also
+match another hold rule remain on hold, as do messages put on hold explicitly
+with "queue hold". Messages are delivered according to
+their current next delivery attempt, see "queue schedule".
+
+	usage: mox queue holdrules resume ruleid
+
 # mox queue list
 
 List matching messages in the delivery queue.
@@ -285,12 +370,16 @@ Prints the message with its ID, last and next delivery attempts, last error.
 	    	account that queued the message
 	  -asc
 	    	sort ascending instead of descending (default)
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -314,12 +403,16 @@ otherwise handled by the admin.
 	usage: mox queue hold [filterflags]
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -341,12 +434,16 @@ delivery attempt. See the "queue schedule" command.
 	usage: mox queue unhold [filterflags]
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -371,12 +468,16 @@ Schedule immediate delivery with "mox queue schedule -now 0".
 	usage: mox queue schedule [filterflags] [-now] duration
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -402,12 +503,16 @@ another mail server or with connections over a SOCKS proxy.
 	usage: mox queue transport [filterflags] transport
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -436,12 +541,16 @@ TLS.
 	usage: mox queue requiretls [filterflags] {yes | no | default}
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -464,12 +573,16 @@ contains a line saying the message was canceled by the admin.
 	usage: mox queue fail [filterflags]
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -491,12 +604,16 @@ the message, use "queue dump" before removing.
 	usage: mox queue drop [filterflags]
 	  -account string
 	    	account that queued the message
+	  -domain string
+	    	recipient domain of message, use ".example.com" to also match subdomains
 	  -from string
 	    	from address of message, use "@example.com" to match all messages for a domain
 	  -hold value
 	    	true or false, whether to match only messages that are (not) on hold
 	  -ids value
 	    	comma-separated list of message IDs
+	  -lasterror string
+	    	text in error of last delivery attempt, case-insensitive
 	  -n int
 	    	number of messages to return
 	  -nextattempt string
@@ -554,6 +671,91 @@ Prints a JSON representation of the information from the retired queue.
 
 	usage: mox queue retired print id
 
+# mox queue dead list
+
+List matching messages in the dead-letter queue.
+
+Messages for which delivery failed permanently are kept in the dead-letter queue
+if QueueDeadLetterPeriod is configured in mox.conf. Prints messages with their
+ID, time of failure and last error.
+
+	usage: mox queue dead list [filterflags]
+	  -account string
+	    	account that queued the message
+	  -from string
+	    	from address of message, use "@example.com" to match all messages for a domain
+	  -ids value
+	    	comma-separated list of dead-letter message IDs
+	  -n int
+	    	number of messages to return
+	  -to string
+	    	recipient address of message, use "@example.com" to match all messages for a domain
+
+# mox queue dead print
+
+Print a message from the dead-letter queue.
+
+Prints a JSON representation of the information from the dead-letter queue,
+including the results of all delivery attempts.
+
+	usage: mox queue dead print id
+
+# mox queue dead dump
+
+Dump a message from the dead-letter queue.
+
+The message is printed to stdout and is in standard internet mail format.
+
+	usage: mox queue dead dump id
+
+# mox queue dead recipient
+
+Change the recipient of a message in the dead-letter queue.
+
+Useful for fixing a mistyped recipient address before requeueing the message.
+Only the SMTP RCPT TO address is changed, not the message headers.
+
+	usage: mox queue dead recipient id address
+
+# mox queue dead requeue
+
+Requeue matching messages from the dead-letter queue.
+
+Matching messages are added to the queue as new messages, with new IDs, for
+immediate delivery with a fresh set of delivery attempts, and removed from the
+dead-letter queue.
+
+	usage: mox queue dead requeue [filterflags]
+	  -account string
+	    	account that queued the message
+	  -from string
+	    	from address of message, use "@example.com" to match all messages for a domain
+	  -ids value
+	    	comma-separated list of dead-letter message IDs
+	  -n int
+	    	number of messages to return
+	  -to string
+	    	recipient address of message, use "@example.com" to match all messages for a domain
+
+# mox queue dead remove
+
+Remove matching messages from the dead-letter queue.
+
+Messages are removed from the dead-letter queue automatically after the
+configured QueueDeadLetterPeriod.
+
+	usage: mox queue dead remove [filterflags]
+	  -account string
+	    	account that queued the message
+	  -from string
+	    	from address of message, use "@example.com" to match all messages for a domain
+	  -ids value
+	    	comma-separated list of dead-letter message IDs
+	  -n int
+	    	number of messages to return
+	  -to string
+	    	recipient address of message, use "@example.com" to match all messages for a domain
+
 # mox queue suppress list
 
 Print addresses in suppression list.
@@ -749,12 +951,24 @@ result in duplicate messages.
 
 Export one or all mailboxes from an account in maildir format.
 
+With -dovecot, the Maildir++ layout is used: The Inbox is the maildir at the
+root of dst-dir, other mailboxes are maildirs in subdirectories named after the
+mailbox with a leading dot and dots as hierarchy separator. A dovecot-uidlist
+file is written for each mailbox, preserving the UIDVALIDITY and message UIDs,
+and a subscriptions file is written, so dst-dir can be used directly as mail
+location for Dovecot, without IMAP clients having to resynchronize. Mailbox
+names are kept in UTF-8, configure Dovecot with the "UTF-8" mail_location option
+for mailboxes with non-ASCII names, and the listescape plugin for mailboxes
+with dots in their names.
+
 Export bypasses a running mox instance. It opens the account mailbox/message
 database file directly. This may block if a running mox instance also has the
 database open, e.g. for IMAP connections. To export from a running instance, use
 the accounts web page or webmail.
 
-	usage: mox export maildir [-single] dst-dir account-path [mailbox]
+	usage: mox export maildir [-single] [-dovecot] dst-dir account-path [mailbox]
+	  -dovecot
+	    	export in maildir++ layout with dovecot-uidlist and subscriptions files, for use as dovecot mail store
 	  -single
 	    	export single mailbox, without any children. disabled if mailbox isn't specified.
 
@@ -777,6 +991,31 @@ otherwise reconstructing the original could lose a ">".
 	  -single
 	    	export single mailbox, without any children. disabled if mailbox isn't specified.
 
+# mox junk export
+
+Export junk filter training data and sender lists of an account.
+
+The export is written to stdout as JSON, with the number of messages trained as
+ham and spam, the ham/spam counts of words, and the sender allow/block list
+entries. It can be imported with "mox junk import", e.g. into an account on
+another mox instance or an account with a new name, so spam filtering doesn't
+start from scratch. Without a junk filter configured for the account, only the
+sender lists are exported.
+
+	usage: mox junk export account >spamdata.json
+
+# mox junk import
+
+Import junk filter training data and sender lists into an account.
+
+The data, as exported with "mox junk export", is read from stdin. The counts of
+trained messages and words are added to those of the junk filter of the
+account, which must be configured if the export has training data. Sender list
+entries are added, replacing the action of existing entries with the same
+pattern.
+
+	usage: mox junk import account <spamdata.json
+
 # mox localserve
 
 Start a local SMTP/IMAP server that accepts all messages, useful when testing/developing software that sends email.
@@ -928,6 +1167,21 @@ configured.
 
 	usage: mox config dnsrecords domain
 
+# mox config dnsprovision
+
+Create or update DNS records for the domain through its DNS provider.
+
+The domain must have DNSProvisioning configured, with a provider from
+DNSProviders in mox.conf. DKIM records for all selectors, the SPF record, the
+MTA-STS record and TLSA records for the mail host are created or updated, but
+only if they are in the DNS zone of the domain. Other TXT records at the domain,
+besides SPF, are left alone. A running mox also does this periodically, along
+with DKIM key rotation if configured.
+
+The changed records are printed.
+
+	usage: mox config dnsprovision domain
+
 # mox config describe-domains
 
 Prints an annotated empty configuration for use as domains.conf.
@@ -972,6 +1226,28 @@ explicitly, see the setaccountpassword command.
 
 	usage: mox config account add account address
 
+# mox config account import
+
+Add multiple accounts from a CSV or JSON file and reload the configuration.
+
+Each CSV line has fields: account name, address, full name, additional
+addresses (space-separated, "@domain" for a catchall address), quota message
+size in bytes, password. All fields after the address are optional. A first
+line starting with field "name" is skipped as header. A JSON file holds an
+array of objects with fields Name, Address, FullName, Addresses,
+QuotaMessageSize and Password.
+
+Either all accounts are added, or none. For each account, a line with the
+account name, address and initial password is printed. Passwords are generated
+for accounts without password. With -requirechange, the password must be
+changed through the account web interface before other logins work.
+
+	usage: mox config account import [-dryrun] [-requirechange] file.csv|file.json
+	  -dryrun
+	    	only check that the accounts can be added
+	  -requirechange
+	    	require accounts to change their initial password
+
 # mox config account rm
 
 Remove an account and reload the configuration.
@@ -1003,6 +1279,57 @@ Login attempts by the user no long result in an error message.
 
 	usage: mox config account enable account
 
+# mox config adminuser list
+
+List admin users for the admin web interface.
+
+Each admin user is printed on a line, with tab-separated role and domains.
+
+	usage: mox config adminuser list
+
+# mox config adminuser add
+
+Add an admin user for the admin web interface.
+
+Role admin has full access. Role domainadmin can only manage the domains
+specified with -domains, along with their accounts, addresses and aliases. Role
+auditor has read-only access.
+
+The password is read from stdin.
+
+Roles only apply to the admin web interface. Commands through the ctl socket,
+like this one, always have full access: The socket is in the data directory, so
+anyone who can connect to it can also read the configuration files, with
+secrets, and the databases directly. Roles would not protect anything there.
+
+	usage: mox config adminuser add [-domains domain1,...] name admin|domainadmin|auditor
+	  -domains string
+	    	comma-separated domains, for role domainadmin
+
+# mox config adminuser update
+
+Change the role and domains of an admin user.
+
+	usage: mox config adminuser update [-domains domain1,...] name admin|domainadmin|auditor
+	  -domains string
+	    	comma-separated domains, for role domainadmin
+
+# mox config adminuser setpassword
+
+Set a new password for an admin user.
+
+The password is read from stdin.
+
+	usage: mox config adminuser setpassword name
+
+# mox config adminuser rm
+
+Remove an admin user.
+
+Active sessions of the admin user are no longer valid.
+
+	usage: mox config adminuser rm name
+
 # mox config address add
 
 Adds an address to an account and reloads the configuration.
@@ -1117,6 +1444,20 @@ error too, for reference.
 
 	usage: mox config tlspubkey gen stem
 
+# mox config tlscerts reload
+
+Reload TLS certificates of listeners from files and certificate sources.
+
+Listeners with KeyCerts or CertSources in their TLS config get their keys and
+certificates loaded again, e.g. after an external ACME client has renewed
+certificates or they were updated in Vault. If loading fails for a listener, it
+keeps its current certificates. Sending a SIGHUP to the mox process also reloads
+certificates.
+
+Names of listeners with reloaded certificates are printed.
+
+	usage: mox config tlscerts reload
+
 # mox config alias list
 
 Show aliases (lists) for domain.
@@ -249,6 +249,12 @@ var examples = []struct {
 				WebhookQueued: exampleTime,
 				Extra:         map[string]string{},
 				SMTPCode:      smtp.C250Completed,
+				Recipient:     "mjl@remote.example",
+				Attempts:      1,
+				RemoteMTA:     "mx.remote.example (192.0.2.10)",
+				SMTPResponse:  []string{"250 2.0.0 ok, queued as 4QlC1X2yW4z"},
+				TLS:           "tls1.3 tls_aes_128_gcm_sha256",
+				DANE:          true,
 			}
 			return "Example webhook HTTP POST JSON body for successful outgoing delivery:\n\n\t" + formatJSON(v)
 		},
@@ -267,13 +273,50 @@ var examples = []struct {
 				Subject:          "subject of original message",
 				WebhookQueued:    exampleTime,
 				Extra:            map[string]string{"userid": "456"},
+				BounceClass:      webhook.BounceSoft,
 				Error:            "timeout connecting to host",
 				SMTPCode:         smtp.C554TransactionFailed,
 				SMTPEnhancedCode: "5." + smtp.SeNet4Other0,
+				Recipient:        "mjl@remote.example",
+				Attempts:         1,
+				SMTPResponse:     []string{},
 			}
 			return `Example webhook HTTP POST JSON body for failed delivery based on incoming DSN
 message, with custom extra data fields (from original submission), and adding address to the suppression list:
 
+	` + formatJSON(v)
+		},
+	},
+	{
+		"webhook-outgoing-delayed",
+		func() string {
+			next := exampleTime.Add(15 * time.Minute)
+			v := webhook.Outgoing{
+				Version:          0,
+				Event:            webhook.EventDelayed,
+				QueueMsgID:       103,
+				FromID:           base64.RawURLEncoding.EncodeToString([]byte("0123456789abcdef")),
+				MessageID:        "<QnxzgulZK51utga6agH_rg@mox.example>",
+				Subject:          "subject of original message",
+				WebhookQueued:    exampleTime,
+				Extra:            map[string]string{},
+				BounceClass:      webhook.BounceSoft,
+				Error:            "transient: 451 4.7.1 greylisted, try again later",
+				SMTPCode:         smtp.C451LocalErr,
+				SMTPEnhancedCode: "4." + smtp.SePol7DeliveryUnauth1,
+				Recipient:        "mjl@remote.example",
+				Attempts:         2,
+				MaxAttempts:      8,
+				NextAttempt:      &next,
+				RemoteMTA:        "mx.remote.example (192.0.2.10)",
+				SMTPResponse:     []string{"451 4.7.1 greylisted, try again later"},
+				TLS:              "tls1.3 tls_aes_128_gcm_sha256",
+				MTASTS:           true,
+				Transcript:       "S: 220 mx.remote.example ESMTP\nC: EHLO mox.example\nS: 250-mx.remote.example\nS: 250 STARTTLS\nC: STARTTLS\nS: 220 2.0.0 go ahead\nC: EHLO mox.example\nS: 250 mx.remote.example\nC: MAIL FROM:<bounce+QnxzgulZK51utga6agH_rg@mox.example>\nS: 250 2.1.0 ok\nC: RCPT TO:<mjl@remote.example>\nS: 451 4.7.1 greylisted, try again later\n",
+			}
+			return `Example webhook HTTP POST JSON body for a delayed delivery, with details about
+the SMTP session of the last delivery attempt, including the transcript:
+
 	` + formatJSON(v)
 		},
 	},
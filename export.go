@@ -12,18 +12,33 @@ import (
 )
 
 func cmdExportMaildir(c *cmd) {
-	c.params = "[-single] dst-dir account-path [mailbox]"
+	c.params = "[-single] [-dovecot] dst-dir account-path [mailbox]"
 	c.help = `Export one or all mailboxes from an account in maildir format.
 
+With -dovecot, the Maildir++ layout is used: The Inbox is the maildir at the
+root of dst-dir, other mailboxes are maildirs in subdirectories named after the
+mailbox with a leading dot and dots as hierarchy separator. A dovecot-uidlist
+file is written for each mailbox, preserving the UIDVALIDITY and message UIDs,
+and a subscriptions file is written, so dst-dir can be used directly as mail
+location for Dovecot, without IMAP clients having to resynchronize. Mailbox
+names are kept in UTF-8, configure Dovecot with the "UTF-8" mail_location option
+for mailboxes with non-ASCII names, and the listescape plugin for mailboxes
+with dots in their names.
+
 Export bypasses a running mox instance. It opens the account mailbox/message
 database file directly. This may block if a running mox instance also has the
 database open, e.g. for IMAP connections. To export from a running instance, use
 the accounts web page or webmail.
 `
-	var single bool
+	var single, dovecot bool
 	c.flag.BoolVar(&single, "single", false, "export single mailbox, without any children. disabled if mailbox isn't specified.")
+	c.flag.BoolVar(&dovecot, "dovecot", false, "export in maildir++ layout with dovecot-uidlist and subscriptions files, for use as dovecot mail store")
 	args := c.Parse()
-	xcmdExport(false, single, args, c)
+	format := store.ExportMaildir
+	if dovecot {
+		format = store.ExportMaildirPP
+	}
+	xcmdExport(format, single, args, c)
 }
 
 func cmdExportMbox(c *cmd) {
@@ -44,10 +59,10 @@ otherwise reconstructing the original could lose a ">".
 	var single bool
 	c.flag.BoolVar(&single, "single", false, "export single mailbox, without any children. disabled if mailbox isn't specified.")
 	args := c.Parse()
-	xcmdExport(true, single, args, c)
+	xcmdExport(store.ExportMbox, single, args, c)
 }
 
-func xcmdExport(mbox, single bool, args []string, c *cmd) {
+func xcmdExport(format store.ExportFormat, single bool, args []string, c *cmd) {
 	if len(args) != 2 && len(args) != 3 {
 		c.Usage()
 	}
@@ -72,7 +87,7 @@ func xcmdExport(mbox, single bool, args []string, c *cmd) {
 	}()
 
 	a := store.DirArchiver{Dir: dst}
-	err = store.ExportMessages(context.Background(), c.log, db, accountDir, a, !mbox, mailbox, nil, !single)
+	err = store.ExportMessages(context.Background(), c.log, db, accountDir, a, format, mailbox, nil, !single)
 	xcheckf(err, "exporting messages")
 	err = a.Close()
 	xcheckf(err, "closing archiver")
@@ -0,0 +1,77 @@
+package http
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+)
+
+// bimiHandle serves the BIMI logo and Verified Mark Certificate of a domain, at
+// https://bimi.<domain>/.well-known/bimi/<selector>.svg and <selector>.pem.
+func bimiHandle(w http.ResponseWriter, r *http.Request) {
+	log := pkglog.WithContext(r.Context())
+
+	host := strings.ToLower(r.Host)
+	if !strings.HasPrefix(host, "bimi.") {
+		http.NotFound(w, r)
+		return
+	}
+	host = strings.TrimPrefix(host, "bimi.")
+	nhost, _, err := net.SplitHostPort(host)
+	if err == nil {
+		// Only relevant for when host has a port.
+		host = nhost
+	}
+	domain, err := dns.ParseDomain(host)
+	if err != nil {
+		log.Debugx("bimi request: bad domain", err, slog.String("host", host))
+		http.NotFound(w, r)
+		return
+	}
+	conf, ok := mox.Conf.Domain(domain)
+	if !ok || conf.Disabled || conf.BIMI == nil {
+		http.NotFound(w, r)
+		return
+	}
+	b := conf.BIMI
+
+	var file, contentType string
+	switch strings.TrimPrefix(r.URL.Path, "/.well-known/bimi/") {
+	case b.SelectorEffective + ".svg":
+		file, contentType = b.LogoFile, "image/svg+xml"
+	case b.SelectorEffective + ".pem":
+		file, contentType = b.AuthorityFile, "application/pem-certificate-chain"
+	}
+	if file == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	p := mox.ConfigDynamicDirPath(file)
+	f, err := os.Open(p)
+	if err != nil {
+		log.Errorx("opening bimi file", err, slog.String("path", p))
+		http.NotFound(w, r)
+		return
+	}
+	defer func() {
+		err := f.Close()
+		log.Check(err, "closing bimi file")
+	}()
+	fi, err := f.Stat()
+	if err != nil {
+		http.Error(w, "500 - internal server error - "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", contentType)
+	// Logos are fetched by mail servers and clients of other parties.
+	h.Set("Access-Control-Allow-Origin", "*")
+	h.Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'")
+	http.ServeContent(w, r, "", fi.ModTime(), f)
+}
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestBIMI(t *testing.T) {
+	os.RemoveAll("../testdata/web/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/web/mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(filepath.Dir(mox.ConfigStaticPath), "domains.conf")
+	mox.MustLoadConfig(true, false)
+
+	portSrvs := portServes("local", mox.Conf.Static.Listeners["local"])
+	srv := portSrvs[80]
+
+	test := func(target string, expCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", target, nil)
+		rw := httptest.NewRecorder()
+		rw.Body = &bytes.Buffer{}
+		srv.ServeHTTP(rw, req)
+		if rw.Code != expCode {
+			t.Fatalf("got statuscode %d, expected %d", rw.Code, expCode)
+		}
+		return rw
+	}
+
+	rw := test("http://bimi.mox.example/.well-known/bimi/default.svg", http.StatusOK)
+	if ct := rw.Header().Get("Content-Type"); ct != "image/svg+xml" || !strings.Contains(rw.Body.String(), "<svg") {
+		t.Fatalf("unexpected logo response, content-type %q, body %q", ct, rw.Body.String())
+	}
+	test("http://bimi.mox.example/.well-known/bimi/default.pem", http.StatusNotFound)   // No authority file.
+	test("http://bimi.mox.example/.well-known/bimi/other.svg", http.StatusNotFound)     // Unknown selector.
+	test("http://bimi.other.example/.well-known/bimi/default.svg", http.StatusNotFound) // No bimi for domain.
+	test("http://mox.example/.well-known/bimi/default.svg", http.StatusNotFound)        // Not on the domain itself.
+}
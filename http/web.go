@@ -31,6 +31,8 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 
 	"github.com/mjl-/mox/autotls"
+	"github.com/mjl-/mox/caldav"
+	"github.com/mjl-/mox/carddav"
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/imapserver"
@@ -540,6 +542,15 @@ func redirectToTrailingSlash(srv *serve, hostMatch func(dns.IPDomain) bool, name
 	}
 }
 
+// davWellKnown redirects /.well-known/<name> to the CalDAV or CardDAV path, for
+// service discovery by clients, RFC 6764.
+func davWellKnown(srv *serve, hostMatch func(dns.IPDomain) bool, name, path string) {
+	handler := mox.SafeHeaders(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, path, http.StatusMovedPermanently)
+	}))
+	srv.ServiceHandle(name, hostMatch, "/.well-known/"+name, handler)
+}
+
 // Listen binds to sockets for HTTP listeners, including those required for ACME to
 // generate TLS certificates. It stores the listeners so Serve can start serving them.
 func Listen() {
@@ -726,6 +737,58 @@ func portServes(name string, l config.Listener) map[int]*serve {
 		redirectToTrailingSlash(srv, accountHostMatch, "webapi", path)
 	}
 
+	if l.CalDAVHTTP.Enabled {
+		port := config.Port(l.CalDAVHTTP.Port, 80)
+		path := "/caldav/"
+		if l.CalDAVHTTP.Path != "" {
+			path = l.CalDAVHTTP.Path
+		}
+		srv := ensureServe(false, port, "caldav-http at "+path, true)
+		handler := mox.SafeHeaders(http.StripPrefix(strings.TrimRight(path, "/"), caldav.NewServer(path, l.CalDAVHTTP.Forwarded)))
+		srv.ServiceHandle("caldav", accountHostMatch, path, handler)
+		redirectToTrailingSlash(srv, accountHostMatch, "caldav", path)
+		davWellKnown(srv, accountHostMatch, "caldav", path)
+		ensureACMEHTTP01(srv)
+	}
+	if l.CalDAVHTTPS.Enabled {
+		port := config.Port(l.CalDAVHTTPS.Port, 443)
+		path := "/caldav/"
+		if l.CalDAVHTTPS.Path != "" {
+			path = l.CalDAVHTTPS.Path
+		}
+		srv := ensureServe(true, port, "caldav-https at "+path, true)
+		handler := mox.SafeHeaders(http.StripPrefix(strings.TrimRight(path, "/"), caldav.NewServer(path, l.CalDAVHTTPS.Forwarded)))
+		srv.ServiceHandle("caldav", accountHostMatch, path, handler)
+		redirectToTrailingSlash(srv, accountHostMatch, "caldav", path)
+		davWellKnown(srv, accountHostMatch, "caldav", path)
+	}
+
+	if l.CardDAVHTTP.Enabled {
+		port := config.Port(l.CardDAVHTTP.Port, 80)
+		path := "/carddav/"
+		if l.CardDAVHTTP.Path != "" {
+			path = l.CardDAVHTTP.Path
+		}
+		srv := ensureServe(false, port, "carddav-http at "+path, true)
+		handler := mox.SafeHeaders(http.StripPrefix(strings.TrimRight(path, "/"), carddav.NewServer(path, l.CardDAVHTTP.Forwarded)))
+		srv.ServiceHandle("carddav", accountHostMatch, path, handler)
+		redirectToTrailingSlash(srv, accountHostMatch, "carddav", path)
+		davWellKnown(srv, accountHostMatch, "carddav", path)
+		ensureACMEHTTP01(srv)
+	}
+	if l.CardDAVHTTPS.Enabled {
+		port := config.Port(l.CardDAVHTTPS.Port, 443)
+		path := "/carddav/"
+		if l.CardDAVHTTPS.Path != "" {
+			path = l.CardDAVHTTPS.Path
+		}
+		srv := ensureServe(true, port, "carddav-https at "+path, true)
+		handler := mox.SafeHeaders(http.StripPrefix(strings.TrimRight(path, "/"), carddav.NewServer(path, l.CardDAVHTTPS.Forwarded)))
+		srv.ServiceHandle("carddav", accountHostMatch, path, handler)
+		redirectToTrailingSlash(srv, accountHostMatch, "carddav", path)
+		davWellKnown(srv, accountHostMatch, "carddav", path)
+	}
+
 	if l.WebmailHTTP.Enabled {
 		port := config.Port(l.WebmailHTTP.Port, 80)
 		path := "/webmail/"
@@ -826,6 +889,36 @@ func portServes(name string, l config.Listener) map[int]*serve {
 		}
 		srv.SystemHandle("mtasts", mtastsMatch, "/.well-known/mta-sts.txt", mox.SafeHeaders(http.HandlerFunc(mtastsPolicyHandle)))
 	}
+	if l.WKDHTTPS.Enabled {
+		port := config.Port(l.WKDHTTPS.Port, 443)
+		srv := ensureServe(!l.WKDHTTPS.NonTLS, port, "wkd-https", false)
+		if l.WKDHTTPS.NonTLS {
+			ensureACMEHTTP01(srv)
+		}
+		wkdMatch := func(ipdom dns.IPDomain) bool {
+			dom := ipdom.Domain
+			if dom.IsZero() {
+				return false
+			}
+			return strings.HasPrefix(dom.ASCII, "openpgpkey.")
+		}
+		srv.SystemHandle("wkd", wkdMatch, "/.well-known/openpgpkey/", mox.SafeHeaders(http.HandlerFunc(wkdHandle)))
+	}
+	if l.BIMIHTTPS.Enabled {
+		port := config.Port(l.BIMIHTTPS.Port, 443)
+		srv := ensureServe(!l.BIMIHTTPS.NonTLS, port, "bimi-https", false)
+		if l.BIMIHTTPS.NonTLS {
+			ensureACMEHTTP01(srv)
+		}
+		bimiMatch := func(ipdom dns.IPDomain) bool {
+			dom := ipdom.Domain
+			if dom.IsZero() {
+				return false
+			}
+			return strings.HasPrefix(dom.ASCII, "bimi.")
+		}
+		srv.SystemHandle("bimi", bimiMatch, "/.well-known/bimi/", mox.SafeHeaders(http.HandlerFunc(bimiHandle)))
+	}
 	if l.PprofHTTP.Enabled {
 		// Importing net/http/pprof registers handlers on the default serve mux.
 		port := config.Port(l.PprofHTTP.Port, 8011)
@@ -1023,7 +1116,7 @@ func Serve() {
 					SupportedVersions: []uint16{tls.VersionTLS13},
 				}
 				pkglog.Print("ensuring certificate availability", slog.Any("hostname", host))
-				if _, err := m.Manager.GetCertificate(hello); err != nil {
+				if _, err := m.GetCertificate(hello); err != nil {
 					pkglog.Errorx("requesting automatic certificate", err, slog.Any("hostname", host))
 				}
 			}
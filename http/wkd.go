@@ -0,0 +1,119 @@
+package http
+
+import (
+	"bytes"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/wkd"
+)
+
+// wkdHandle serves OpenPGP keys published by accounts in the Web Key Directory,
+// with the "advanced" method, at
+// https://openpgpkey.<domain>/.well-known/openpgpkey/<domain>/hu/<hash>?l=<localpart>.
+func wkdHandle(w http.ResponseWriter, r *http.Request) {
+	log := pkglog.WithContext(r.Context())
+
+	host := strings.ToLower(r.Host)
+	if !strings.HasPrefix(host, "openpgpkey.") {
+		http.NotFound(w, r)
+		return
+	}
+	host = strings.TrimPrefix(host, "openpgpkey.")
+	nhost, _, err := net.SplitHostPort(host)
+	if err == nil {
+		// Only relevant for when host has a port.
+		host = nhost
+	}
+	domain, err := dns.ParseDomain(host)
+	if err != nil {
+		log.Debugx("wkd request: bad domain", err, slog.String("host", host))
+		http.NotFound(w, r)
+		return
+	}
+	if conf, ok := mox.Conf.Domain(domain); !ok || conf.ReportsOnly || conf.Disabled {
+		http.NotFound(w, r)
+		return
+	}
+
+	// Path is /.well-known/openpgpkey/<domain>/hu/<hash> or
+	// /.well-known/openpgpkey/<domain>/policy.
+	dom, rest, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/.well-known/openpgpkey/"), "/")
+	if !strings.EqualFold(dom, domain.ASCII) {
+		http.NotFound(w, r)
+		return
+	}
+	// Clients, e.g. in browsers, may fetch keys from other origins.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if rest == "policy" {
+		// An empty policy, we don't implement the Web Key Directory Update Protocol.
+		w.Header().Set("Content-Type", "text/plain")
+		return
+	}
+	hash, ok := strings.CutPrefix(rest, "hu/")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	// We need the localpart to find the account.
+	lp := smtp.Localpart(r.URL.Query().Get("l"))
+	if lp == "" || wkd.Hash(lp) != hash {
+		http.NotFound(w, r)
+		return
+	}
+	accName, alias, _, _, err := mox.LookupAddress(lp, domain, false, false, true)
+	if err != nil || alias != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	acc, err := store.OpenAccount(log, accName, false)
+	if err != nil {
+		log.Errorx("open account for wkd request", err, slog.String("account", accName))
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	keys, err := bstore.QueryDB[store.PGPKey](r.Context(), acc.DB).FilterEqual("Publish", true).List()
+	if err != nil {
+		log.Errorx("listing pgp keys for wkd request", err, slog.String("account", accName))
+		http.Error(w, "500 - internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	// Serve the binary keys with an address matching the hash, which is case-insensitive.
+	var buf bytes.Buffer
+	for _, k := range keys {
+		e, err := store.PGPKey{PublicKey: k.PublicKey}.Entity("")
+		if err != nil {
+			log.Errorx("parsing stored pgp key", err, slog.String("fingerprint", k.Fingerprint))
+			continue
+		}
+		for _, a := range wkd.EntityAddresses(e) {
+			if a.Domain == domain && wkd.Hash(a.Localpart) == hash {
+				err := e.Serialize(&buf)
+				log.Check(err, "writing pgp key")
+				break
+			}
+		}
+	}
+	if buf.Len() == 0 {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	_, _ = w.Write(buf.Bytes())
+}
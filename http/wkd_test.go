@@ -0,0 +1,99 @@
+package http
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/wkd"
+)
+
+func TestWKD(t *testing.T) {
+	os.RemoveAll("../testdata/web/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/web/mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(filepath.Dir(mox.ConfigStaticPath), "domains.conf")
+	mox.MustLoadConfig(true, false)
+	ctx := context.Background()
+	err := store.Init(ctx)
+	if err != nil {
+		t.Fatalf("store init: %v", err)
+	}
+	defer func() {
+		err := store.Close()
+		if err != nil {
+			t.Fatalf("store close: %v", err)
+		}
+	}()
+
+	log := mlog.New("http", nil)
+	acc, err := store.OpenAccount(log, "mjl", false)
+	if err != nil {
+		t.Fatalf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	e, err := openpgp.NewEntity("mjl", "", "mjl@mox.example", nil)
+	if err != nil {
+		t.Fatalf("new entity: %v", err)
+	}
+	var pub bytes.Buffer
+	w, _ := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	e.Serialize(w)
+	w.Close()
+	k, err := store.ParsePGPKey(pub.String())
+	if err != nil {
+		t.Fatalf("parse key: %v", err)
+	}
+	err = acc.DB.Insert(ctx, &k)
+	if err != nil {
+		t.Fatalf("insert key: %v", err)
+	}
+
+	portSrvs := portServes("local", mox.Conf.Static.Listeners["local"])
+	srv := portSrvs[80]
+
+	test := func(target string, expCode int) []byte {
+		t.Helper()
+		req := httptest.NewRequest("GET", target, nil)
+		rw := httptest.NewRecorder()
+		rw.Body = &bytes.Buffer{}
+		srv.ServeHTTP(rw, req)
+		if rw.Code != expCode {
+			t.Fatalf("got statuscode %d, expected %d", rw.Code, expCode)
+		}
+		return rw.Body.Bytes()
+	}
+
+	hash := wkd.Hash("mjl")
+	test("http://openpgpkey.mox.example/.well-known/openpgpkey/mox.example/policy", http.StatusOK)
+	test("http://openpgpkey.mox.example/.well-known/openpgpkey/mox.example/hu/"+hash+"?l=mjl", http.StatusNotFound) // Not published.
+
+	k.Publish = true
+	err = acc.DB.Update(ctx, &k)
+	if err != nil {
+		t.Fatalf("update key: %v", err)
+	}
+	buf := test("http://openpgpkey.mox.example/.well-known/openpgpkey/mox.example/hu/"+hash+"?l=MJL", http.StatusOK)
+	l, err := openpgp.ReadKeyRing(bytes.NewReader(buf))
+	if err != nil || len(l) != 1 || l[0].PrimaryKey.Fingerprint != e.PrimaryKey.Fingerprint {
+		t.Fatalf("unexpected keys %v, err %v", l, err)
+	}
+	test("http://openpgpkey.mox.example/.well-known/openpgpkey/mox.example/hu/"+hash, http.StatusNotFound)                         // Missing localpart.
+	test("http://openpgpkey.mox.example/.well-known/openpgpkey/other.example/hu/"+hash+"?l=mjl", http.StatusNotFound)              // Other domain.
+	test("http://openpgpkey.mox.example/.well-known/openpgpkey/mox.example/hu/"+wkd.Hash("other")+"?l=other", http.StatusNotFound) // Unknown address.
+	test("http://mox.example/.well-known/openpgpkey/mox.example/hu/"+hash+"?l=mjl", http.StatusNotFound)                           // Not on the domain itself.
+}
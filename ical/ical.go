@@ -0,0 +1,301 @@
+// Package ical parses and writes iCalendar data, as used for meeting invitations
+// sent by email.
+//
+// Only the generic structure of components and properties is parsed, with helpers
+// for the text and date/time values of events. Recurrence rules are not
+// interpreted.
+//
+// See RFC 5545 for iCalendar, RFC 5546 for iTIP (scheduling methods such as
+// REQUEST and REPLY) and RFC 6047 for iMIP (iTIP over email).
+package ical
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+var ErrSyntax = errors.New("ical syntax error")
+
+// Property is a single property of a component, e.g. SUMMARY or DTSTART.
+type Property struct {
+	Name   string              // Upper case.
+	Params map[string][]string // Names are upper case. Values without quotes.
+	Value  string              // Raw value, text values are still escaped.
+}
+
+// Component is a calendar component, e.g. VCALENDAR or VEVENT, with its
+// properties and subcomponents.
+type Component struct {
+	Name       string // Upper case.
+	Properties []Property
+	Components []Component
+}
+
+// Maximum length of a line after unfolding, and of a calendar.
+const (
+	maxLine = 64 * 1024
+	maxSize = 1024 * 1024
+)
+
+// Parse parses a single iCalendar object, typically a VCALENDAR component.
+func Parse(r io.Reader) (*Component, error) {
+	s := bufio.NewScanner(&io.LimitedReader{R: r, N: maxSize})
+	s.Buffer(make([]byte, 0, 4096), maxLine)
+
+	// Unfold lines: lines starting with a space or tab continue the previous line.
+	var lines []string
+	for s.Scan() {
+		line := strings.TrimSuffix(s.Text(), "\r")
+		if line != "" && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			if len(lines[len(lines)-1]) > maxLine {
+				return nil, fmt.Errorf("%w: line too long", ErrSyntax)
+			}
+		} else if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("reading calendar: %w", err)
+	}
+
+	var stack []*Component
+	var root *Component
+	for i, line := range lines {
+		p, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		switch p.Name {
+		case "BEGIN":
+			if root != nil {
+				return nil, fmt.Errorf("%w: data after end of calendar", ErrSyntax)
+			}
+			stack = append(stack, &Component{Name: strings.ToUpper(p.Value)})
+		case "END":
+			if len(stack) == 0 || stack[len(stack)-1].Name != strings.ToUpper(p.Value) {
+				return nil, fmt.Errorf("%w: line %d: unexpected end of component %q", ErrSyntax, i+1, p.Value)
+			}
+			c := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) == 0 {
+				root = c
+			} else {
+				parent := stack[len(stack)-1]
+				parent.Components = append(parent.Components, *c)
+			}
+		default:
+			if len(stack) == 0 {
+				return nil, fmt.Errorf("%w: line %d: property outside component", ErrSyntax, i+1)
+			}
+			c := stack[len(stack)-1]
+			c.Properties = append(c.Properties, p)
+		}
+	}
+	if len(stack) > 0 {
+		return nil, fmt.Errorf("%w: missing end of component %q", ErrSyntax, stack[len(stack)-1].Name)
+	} else if root == nil {
+		return nil, fmt.Errorf("%w: no component", ErrSyntax)
+	}
+	return root, nil
+}
+
+// parseLine parses a content line: name *(";" param) ":" value.
+func parseLine(line string) (Property, error) {
+	var p Property
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return p, fmt.Errorf("%w: missing property name", ErrSyntax)
+	}
+	p.Name = strings.ToUpper(line[:i])
+	s := line[i:]
+	for s[0] == ';' {
+		s = s[1:]
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return p, fmt.Errorf("%w: parameter without value", ErrSyntax)
+		}
+		name := strings.ToUpper(s[:eq])
+		s = s[eq+1:]
+		for {
+			var v string
+			if strings.HasPrefix(s, `"`) {
+				end := strings.IndexByte(s[1:], '"')
+				if end < 0 {
+					return p, fmt.Errorf("%w: unterminated quoted parameter value", ErrSyntax)
+				}
+				v, s = s[1:1+end], s[2+end:]
+			} else {
+				end := strings.IndexAny(s, ",;:")
+				if end < 0 {
+					return p, fmt.Errorf("%w: missing value", ErrSyntax)
+				}
+				v, s = s[:end], s[end:]
+			}
+			if p.Params == nil {
+				p.Params = map[string][]string{}
+			}
+			p.Params[name] = append(p.Params[name], v)
+			if s == "" || s[0] != ',' {
+				break
+			}
+			s = s[1:]
+		}
+		if s == "" {
+			return p, fmt.Errorf("%w: missing value", ErrSyntax)
+		}
+	}
+	if s[0] != ':' {
+		return p, fmt.Errorf("%w: missing colon before value", ErrSyntax)
+	}
+	p.Value = s[1:]
+	return p, nil
+}
+
+// Param returns the first value of parameter name, or an empty string.
+func (p Property) Param(name string) string {
+	if l := p.Params[strings.ToUpper(name)]; len(l) > 0 {
+		return l[0]
+	}
+	return ""
+}
+
+// Text returns the unescaped value of a property with a text value.
+func (p Property) Text() string {
+	return Unescape(p.Value)
+}
+
+// Time parses the value of a date or date-time property, like DTSTART. For
+// values with only a date, allDay is set and the time is midnight UTC. Times
+// with a TZID parameter are in that time zone if it is known. Times without
+// time zone ("floating") are returned as UTC.
+func (p Property) Time() (t time.Time, allDay bool, rerr error) {
+	v := p.Value
+	if strings.EqualFold(p.Param("VALUE"), "DATE") || len(v) == len("20060102") {
+		t, err := time.Parse("20060102", v)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("%w: parsing date: %v", ErrSyntax, err)
+		}
+		return t, true, nil
+	}
+	loc := time.UTC
+	if strings.HasSuffix(v, "Z") {
+		v = v[:len(v)-1]
+	} else if tzid := p.Param("TZID"); tzid != "" {
+		if l, err := time.LoadLocation(strings.TrimPrefix(tzid, "/")); err == nil {
+			loc = l
+		}
+	}
+	t, err := time.ParseInLocation("20060102T150405", v, loc)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("%w: parsing date-time: %v", ErrSyntax, err)
+	}
+	return t, false, nil
+}
+
+// Property returns the first property with name, or nil.
+func (c *Component) Property(name string) *Property {
+	name = strings.ToUpper(name)
+	for i := range c.Properties {
+		if c.Properties[i].Name == name {
+			return &c.Properties[i]
+		}
+	}
+	return nil
+}
+
+// Text returns the unescaped text value of the first property with name, or an
+// empty string.
+func (c *Component) Text(name string) string {
+	if p := c.Property(name); p != nil {
+		return p.Text()
+	}
+	return ""
+}
+
+// Unescape unescapes a text value.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 == len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// Escape escapes s for use as text value.
+func Escape(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+	return r.Replace(s)
+}
+
+// Marshal returns the component in iCalendar form, with CRLF line endings and
+// lines folded at 75 octets.
+func (c *Component) Marshal() []byte {
+	var b bytes.Buffer
+	c.write(&b)
+	return b.Bytes()
+}
+
+func (c *Component) write(b *bytes.Buffer) {
+	writeLine(b, "BEGIN:"+c.Name)
+	for _, p := range c.Properties {
+		var sb strings.Builder
+		sb.WriteString(p.Name)
+		for _, name := range slices.Sorted(maps.Keys(p.Params)) {
+			values := p.Params[name]
+			sb.WriteString(";" + name + "=")
+			for i, v := range values {
+				if i > 0 {
+					sb.WriteString(",")
+				}
+				if strings.ContainsAny(v, ";:,") {
+					v = `"` + v + `"`
+				}
+				sb.WriteString(v)
+			}
+		}
+		sb.WriteString(":" + p.Value)
+		writeLine(b, sb.String())
+	}
+	for _, sc := range c.Components {
+		sc.write(b)
+	}
+	writeLine(b, "END:"+c.Name)
+}
+
+// writeLine writes line, folded so no line is longer than 75 octets, without
+// splitting UTF-8 characters.
+func writeLine(b *bytes.Buffer, line string) {
+	max := 75
+	for len(line) > max {
+		n := max
+		for n > 0 && !utf8.RuneStart(line[n]) {
+			n--
+		}
+		b.WriteString(line[:n] + "\r\n ")
+		line = line[n:]
+		max = 74 // Continuation lines start with a space.
+	}
+	b.WriteString(line + "\r\n")
+}
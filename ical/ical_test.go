@@ -0,0 +1,104 @@
+package ical
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const invite = "BEGIN:VCALENDAR\r\n" +
+	"PRODID:-//Example//Test//EN\r\n" +
+	"VERSION:2.0\r\n" +
+	"METHOD:REQUEST\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:123@mox.example\r\n" +
+	"SEQUENCE:1\r\n" +
+	"DTSTART;TZID=Europe/Amsterdam:20240102T150000\r\n" +
+	"DTEND:20240102T150000Z\r\n" +
+	"SUMMARY:Meeting\\, with comma\r\n" +
+	"DESCRIPTION:line one\\nline two that is long enough to be folded over mult\r\n" +
+	" iple lines\r\n" +
+	"ORGANIZER;CN=\"Mox, Admin\":mailto:admin@mox.example\r\n" +
+	"ATTENDEE;PARTSTAT=NEEDS-ACTION;ROLE=REQ-PARTICIPANT;CN=mjl:mailto:mjl@mox.example\r\n" +
+	"ATTENDEE;DELEGATED-TO=\"mailto:a@mox.example\",\"mailto:b@mox.example\":mailto:other@mox.example\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParse(t *testing.T) {
+	c, err := Parse(strings.NewReader(invite))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if c.Name != "VCALENDAR" || c.Text("METHOD") != "REQUEST" || len(c.Components) != 1 {
+		t.Fatalf("unexpected calendar %#v", c)
+	}
+	ev := c.Components[0]
+	if s := ev.Text("summary"); s != "Meeting, with comma" {
+		t.Fatalf("summary %q", s)
+	}
+	if s := ev.Text("DESCRIPTION"); s != "line one\nline two that is long enough to be folded over multiple lines" {
+		t.Fatalf("description %q", s)
+	}
+	org := ev.Property("ORGANIZER")
+	if org.Param("cn") != "Mox, Admin" || org.Value != "mailto:admin@mox.example" {
+		t.Fatalf("organizer %#v", org)
+	}
+	var attendees []Property
+	for _, p := range ev.Properties {
+		if p.Name == "ATTENDEE" {
+			attendees = append(attendees, p)
+		}
+	}
+	if len(attendees) != 2 || attendees[0].Param("PARTSTAT") != "NEEDS-ACTION" || !reflect.DeepEqual(attendees[1].Params["DELEGATED-TO"], []string{"mailto:a@mox.example", "mailto:b@mox.example"}) {
+		t.Fatalf("attendees %#v", attendees)
+	}
+
+	start, allDay, err := ev.Property("DTSTART").Time()
+	if err != nil || allDay || !start.Equal(time.Date(2024, 1, 2, 14, 0, 0, 0, time.UTC)) {
+		t.Fatalf("dtstart %v %v %v", start, allDay, err)
+	}
+	end, allDay, err := ev.Property("DTEND").Time()
+	if err != nil || allDay || !end.Equal(time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)) {
+		t.Fatalf("dtend %v %v %v", end, allDay, err)
+	}
+	day, allDay, err := Property{Name: "DTSTART", Params: map[string][]string{"VALUE": {"DATE"}}, Value: "20240102"}.Time()
+	if err != nil || !allDay || !day.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("date %v %v %v", day, allDay, err)
+	}
+
+	// Marshal and parse again.
+	buf := c.Marshal()
+	for _, line := range bytes.Split(buf, []byte("\r\n")) {
+		if len(line) > 75 {
+			t.Fatalf("line too long: %q", line)
+		}
+	}
+	nc, err := Parse(bytes.NewReader(buf))
+	if err != nil {
+		t.Fatalf("parse marshaled: %v", err)
+	}
+	if !reflect.DeepEqual(c, nc) {
+		t.Fatalf("marshaled calendar differs:\n%#v\n%#v", c, nc)
+	}
+
+	bad := func(s string) {
+		t.Helper()
+		_, err := Parse(strings.NewReader(s))
+		if err == nil || !errors.Is(err, ErrSyntax) {
+			t.Fatalf("got err %v, expected ErrSyntax", err)
+		}
+	}
+	bad("")
+	bad("SUMMARY:test\r\n")
+	bad("BEGIN:VCALENDAR\r\n")
+	bad("BEGIN:VCALENDAR\r\nEND:VEVENT\r\n")
+	bad("BEGIN:VCALENDAR\r\nnovalue\r\nEND:VCALENDAR\r\n")
+	bad("BEGIN:VCALENDAR\r\nX;P=\"unterminated:x\r\nEND:VCALENDAR\r\n")
+
+	if s := Escape("a,b;c\\d\ne"); s != `a\,b\;c\\d\ne` || Unescape(s) != "a,b;c\\d\ne" {
+		t.Fatalf("escape %q", s)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/mjl-/mox/imapclient"
+	"github.com/mjl-/mox/mox-"
 )
 
 func TestQuota1(t *testing.T) {
@@ -52,3 +53,27 @@ func TestQuota1(t *testing.T) {
 	tclimit.transactf("ok", "status inbox (DELETED-STORAGE)")
 	tclimit.xuntagged(imapclient.UntaggedStatus{Mailbox: "Inbox", Attrs: map[imapclient.StatusAttr]int64{imapclient.StatusDeletedStorage: 0}})
 }
+
+// Logins get an alert when the account is near its quota.
+func TestQuotaAlert(t *testing.T) {
+	tc := start(t, false)
+	defer tc.close()
+
+	tc.login("mjl@mox.example", password0)
+	tc.transactf("ok", "append inbox {10+}\r\nsubject: x")
+
+	conf, _ := mox.Conf.Account("mjl")
+	conf.QuotaMessageSize = 11
+	mox.Conf.Dynamic.Accounts["mjl"] = conf
+	defer func() {
+		conf.QuotaMessageSize = 0
+		mox.Conf.Dynamic.Accounts["mjl"] = conf
+	}()
+
+	tc2 := startNoSwitchboard(t, false)
+	defer tc2.closeNoWait()
+	resp, err := tc2.client.Login("mjl@mox.example", password0)
+	tcheck(t, err, "login")
+	tc2.lastResponse = resp
+	tc2.xuntagged(imapclient.UntaggedResult{Status: imapclient.OK, Code: imapclient.CodeWord("ALERT"), Text: "Storage is at 90% of quota, remove messages to make room for new messages."})
+}
@@ -75,6 +75,7 @@ import (
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/moxio"
 	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/oidc"
 	"github.com/mjl-/mox/ratelimit"
 	"github.com/mjl-/mox/scram"
 	"github.com/mjl-/mox/store"
@@ -934,6 +935,11 @@ func serve(listenerName string, cid int64, tlsConfig *tls.Config, nc net.Conn, x
 		c.command()
 		c.xflush() // For flushing errors, or commands that did not flush explicitly.
 
+		// For listing and revoking sessions of the account.
+		if c.account != nil {
+			mox.Connections.Activity(nc, "imap", c.account.Name, c.username, c.userAgent)
+		}
+
 		// Flush login attempt if it hasn't already been flushed by an ID command within 1s
 		// after authentication.
 		if c.loginAttempt != nil && (c.loginAttempt.UserAgent != "" || time.Since(c.loginAttemptTime) >= time.Second) {
@@ -2387,6 +2393,9 @@ func (c *conn) capabilities() string {
 	}
 	if c.tls || c.noRequireSTARTTLS {
 		caps += " AUTH=PLAIN"
+		if oidc.Enabled() {
+			caps += " AUTH=OAUTHBEARER AUTH=XOAUTH2"
+		}
 	} else {
 		caps += " LOGINDISABLED"
 	}
@@ -2686,13 +2695,14 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 		}
 
 		var err error
-		account, c.loginAttempt.AccountName, err = store.OpenEmailAuth(c.log, username, password, false)
+		account, c.loginAttempt.AccountName, err = store.OpenEmailAuth(c.log, username, password, "imap", c.remoteIP, false)
 		if err != nil {
 			if errors.Is(err, store.ErrUnknownCredentials) {
 				c.loginAttempt.Result = store.AuthBadCredentials
 				c.log.Info("authentication failed", slog.String("username", username))
 				xusercodeErrorf("AUTHENTICATIONFAILED", "bad credentials")
 			}
+			c.xcheckAuthRefused(err)
 			xusercodeErrorf("", "error")
 		}
 
@@ -2724,6 +2734,7 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 			}
 			xserverErrorf("looking up address: %v", err)
 		}
+		c.xcheckAuthRefused(store.CheckLoginLockout(account.Name))
 		var ipadhash, opadhash hash.Hash
 		account.WithRLock(func() {
 			err := account.DB.Read(context.TODO(), func(tx *bstore.Tx) error {
@@ -2754,6 +2765,7 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 		opadhash.Write(ipadhash.Sum(nil))
 		digest := fmt.Sprintf("%x", opadhash.Sum(nil))
 		if digest != t[1] {
+			c.loginAttempt.Result = store.AuthBadCredentials
 			c.log.Info("failed authentication attempt", slog.String("username", username), slog.Any("remote", c.remoteIP))
 			xusercodeErrorf("AUTHENTICATIONFAILED", "bad credentials")
 		}
@@ -2801,6 +2813,7 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 			// learn if an account exists. same for absent scram saltedpassword below.
 			xuserErrorf("scram not possible")
 		}
+		c.xcheckAuthRefused(store.CheckLoginLockout(account.Name))
 		if ss.Authorization != "" && ss.Authorization != username {
 			xuserErrorf("authentication with authorization for different user not supported")
 		}
@@ -2860,6 +2873,63 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 		// The message should be empty. todo: should we require it is empty?
 		xreadContinuation()
 
+	case "OAUTHBEARER", "XOAUTH2":
+		c.loginAttempt.AuthMech = strings.ToLower(authType)
+
+		if !oidc.Enabled() {
+			xuserErrorf("method not supported")
+		}
+		if !c.noRequireSTARTTLS && !c.tls {
+			// ../rfc/9051:5194
+			xusercodeErrorf("PRIVACYREQUIRED", "tls required for login")
+		}
+
+		// Bearer tokens are credentials, mark as traceauth.
+		defer c.xtraceread(mlog.LevelTraceauth)()
+		buf := xreadInitial()
+		c.xtraceread(mlog.LevelTrace) // Restore.
+		var authz, token string
+		var err error
+		if c.loginAttempt.AuthMech == "oauthbearer" {
+			authz, token, err = oidc.ParseOAuthBearer(buf)
+		} else {
+			authz, token, err = oidc.ParseXOAUTH2(buf)
+		}
+		if err != nil {
+			c.loginAttempt.Result = store.AuthBadProtocol
+			xsyntaxErrorf("parsing %s response: %v", authType, err)
+		}
+		username = norm.NFC.String(authz)
+		c.loginAttempt.LoginAddress = username
+
+		address, err := oidc.TokenAddress(mox.Context, c.log, token)
+		if err == nil && username != "" && !strings.EqualFold(username, address) {
+			err = fmt.Errorf("%w: token is for address %q", oidc.ErrInvalidToken, address)
+		}
+		if err != nil && errors.Is(err, oidc.ErrInvalidToken) {
+			c.loginAttempt.Result = store.AuthBadCredentials
+			c.log.Infox("failed bearer token authentication attempt", err, slog.String("username", username), slog.Any("remote", c.remoteIP))
+			// Error in a challenge, the client sends a dummy response, then we fail. RFC 7628
+			// section 3.2.3.
+			c.xwritelinef("+ %s", base64.StdEncoding.EncodeToString(oidc.ErrorChallenge()))
+			xreadContinuation()
+			xusercodeErrorf("AUTHENTICATIONFAILED", "bad credentials")
+		}
+		xcheckf(err, "verifying bearer token")
+		if username == "" {
+			username = address
+			c.loginAttempt.LoginAddress = username
+		}
+		account, c.loginAttempt.AccountName, _, err = store.OpenEmail(c.log, username, false)
+		if err != nil {
+			if errors.Is(err, store.ErrUnknownCredentials) {
+				c.loginAttempt.Result = store.AuthBadCredentials
+				c.log.Info("failed bearer token authentication attempt, no account for address", slog.String("username", username), slog.Any("remote", c.remoteIP))
+				xusercodeErrorf("AUTHENTICATIONFAILED", "bad credentials")
+			}
+			xserverErrorf("looking up address: %v", err)
+		}
+
 	case "EXTERNAL":
 		c.loginAttempt.AuthMech = "external"
 
@@ -2895,6 +2965,12 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 		c.log.Info("account login disabled", slog.String("username", username))
 		// No AUTHENTICATIONFAILED code, clients could prompt users for different password.
 		xuserErrorf("%w: %s", store.ErrLoginDisabled, accConf.LoginDisabled)
+	} else if c.loginAttempt.AuthMech == "cram-md5" || strings.HasPrefix(c.loginAttempt.AuthMech, "scram-") {
+		// Mechanisms that verified the account password without OpenEmailAuth.
+		if err := account.CheckPasswordLogin(); err != nil {
+			c.xcheckAuthRefused(err)
+			xcheckf(err, "checking password")
+		}
 	}
 
 	// We may already have TLS credentials. They won't have been enabled, or we could
@@ -2934,9 +3010,51 @@ func (c *conn) cmdAuthenticate(tag, cmd string, p *parser) {
 	c.loginAttempt.Result = store.AuthSuccess
 	c.authFailed = 0
 	c.state = stateAuthenticated
+	c.quotaAlert()
 	c.xwriteresultf("%s OK [CAPABILITY %s] authenticate done", tag, c.capabilities())
 }
 
+// quotaAlert writes an untagged ALERT if the account is near its quota or over its
+// soft limit, for clients to show to the user.
+func (c *conn) quotaAlert() {
+	var qs store.QuotaStatus
+	err := c.account.DB.Read(context.TODO(), func(tx *bstore.Tx) error {
+		var err error
+		qs, err = c.account.QuotaStatus(tx)
+		return err
+	})
+	if err != nil {
+		c.log.Errorx("get quota status", err)
+		return
+	}
+	if warning := qs.Warning(); warning != "" {
+		c.xbwritelinef("* OK [ALERT] %s", warning)
+	}
+}
+
+// xcheckAuthRefused aborts the authentication attempt if err indicates logins for
+// the account are locked after too many failed attempts, the password has
+// expired, or an app password must be used.
+func (c *conn) xcheckAuthRefused(err error) {
+	if errors.Is(err, store.ErrLoginLocked) {
+		c.loginAttempt.Result = store.AuthLoginLocked
+		c.log.Info("account login locked", slog.String("username", c.loginAttempt.LoginAddress))
+		// Response code from RFC 5530 for temporary failures.
+		xusercodeErrorf("UNAVAILABLE", "%s", err)
+	} else if errors.Is(err, store.ErrPasswordExpired) {
+		c.loginAttempt.Result = store.AuthPasswordExpired
+		c.log.Info("account password expired", slog.String("username", c.loginAttempt.LoginAddress))
+		// Response code from RFC 5530 for a password that must be changed. Not
+		// AUTHENTICATIONFAILED, clients could prompt for a different password.
+		xusercodeErrorf("EXPIRED", "%s", err)
+	} else if errors.Is(err, store.ErrAppPassword) {
+		c.loginAttempt.Result = store.AuthAppPasswordRequired
+		c.log.Info("account password used while app password required", slog.String("username", c.loginAttempt.LoginAddress))
+		// Clients should prompt for a different password, i.e. the app password.
+		xusercodeErrorf("AUTHENTICATIONFAILED", "%s", err)
+	}
+}
+
 // Login logs in with username and password.
 //
 // Status: Not authenticated.
@@ -2980,7 +3098,7 @@ func (c *conn) cmdLogin(tag, cmd string, p *parser) {
 		}
 	}()
 
-	account, accName, err := store.OpenEmailAuth(c.log, username, password, true)
+	account, accName, err := store.OpenEmailAuth(c.log, username, password, "imap", c.remoteIP, true)
 	c.loginAttempt.AccountName = accName
 	if err != nil {
 		var code string
@@ -2997,6 +3115,7 @@ func (c *conn) cmdLogin(tag, cmd string, p *parser) {
 			// authenticated. So we don't send anything. ../rfc/9051:4940
 			xuserErrorf("%s", err)
 		}
+		c.xcheckAuthRefused(err)
 		xusercodeErrorf(code, "login failed")
 	}
 	defer func() {
@@ -3042,6 +3161,7 @@ func (c *conn) cmdLogin(tag, cmd string, p *parser) {
 	c.authFailed = 0
 	c.setSlow(false)
 	c.state = stateAuthenticated
+	c.quotaAlert()
 	c.xwriteresultf("%s OK [CAPABILITY %s] login done", tag, c.capabilities())
 }
 
@@ -4780,6 +4900,9 @@ func (c *conn) cmdxCopy(isUID bool, tag, cmd string, p *parser) {
 					xcheckf(err, "inserting message recipient")
 				}
 
+				err = store.SearchIndexCopy(tx, origID, m.ID)
+				xcheckf(err, "copying search index entries for message")
+
 				mbDst.Add(m.MailboxCounts())
 			}
 
@@ -5018,7 +5141,9 @@ func (c *conn) xmoveMessages(tx *bstore.Tx, q *bstore.Query[store.Message], expe
 			nm.Seen = false
 		}
 
-		nm.JunkFlagsForMailbox(*mbDst, accConf)
+		nm.JunkFlagsForMove(*mbSrc, *mbDst, accConf)
+		err = store.ScreeningApproveMove(tx, *mbSrc, *mbDst, nm, accConf)
+		xcheckf(err, "approving sender for screening")
 
 		err = tx.Update(&nm)
 		xcheckf(err, "updating message with new mailbox")
@@ -5051,6 +5176,9 @@ func (c *conn) xmoveMessages(tx *bstore.Tx, q *bstore.Query[store.Message], expe
 
 		mbDst.Keywords, _ = store.MergeKeywords(mbDst.Keywords, nm.Keywords)
 
+		err = store.SenderReputationUpdate(context.TODO(), c.log, tx, &nm)
+		xcheckf(err, "updating sender reputation after moving")
+
 		if accConf.JunkFilter != nil && nm.NeedsTraining() {
 			// Lazily open junk filter.
 			if jf == nil {
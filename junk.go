@@ -17,6 +17,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	mathrand "math/rand"
 	"os"
@@ -431,3 +432,55 @@ func cmdJunkPlay(c *cmd) {
 	fmt.Printf("sensitivity (true positives, spams identified): %.6f\n", float64(nspamok)/(float64(nspamok+nspambad)))
 	fmt.Printf("accuracy: %.6f\n", float64(nhamok+nspamok)/float64(nhamok+nhambad+nspamok+nspambad))
 }
+
+func cmdJunkExport(c *cmd) {
+	c.params = "account >spamdata.json"
+	c.help = `Export junk filter training data and sender lists of an account.
+
+The export is written to stdout as JSON, with the number of messages trained as
+ham and spam, the ham/spam counts of words, and the sender allow/block list
+entries. It can be imported with "mox junk import", e.g. into an account on
+another mox instance or an account with a new name, so spam filtering doesn't
+start from scratch. Without a junk filter configured for the account, only the
+sender lists are exported.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdJunkExport(xctl(), args[0])
+}
+
+func ctlcmdJunkExport(ctl *ctl, account string) {
+	ctl.xwrite("junkexport")
+	ctl.xwrite(account)
+	ctl.xreadok()
+	ctl.xstreamto(os.Stdout)
+}
+
+func cmdJunkImport(c *cmd) {
+	c.params = "account <spamdata.json"
+	c.help = `Import junk filter training data and sender lists into an account.
+
+The data, as exported with "mox junk export", is read from stdin. The counts of
+trained messages and words are added to those of the junk filter of the
+account, which must be configured if the export has training data. Sender list
+entries are added, replacing the action of existing entries with the same
+pattern.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdJunkImport(xctl(), args[0], os.Stdin)
+}
+
+func ctlcmdJunkImport(ctl *ctl, account string, r io.Reader) {
+	ctl.xwrite("junkimport")
+	ctl.xwrite(account)
+	ctl.xstreamfrom(r)
+	ctl.xreadok()
+	fmt.Println("junk filter training data and sender lists imported")
+}
@@ -791,3 +791,75 @@ func (f *Filter) fileSize(p string) int {
 func (f *Filter) DB() *bstore.DB {
 	return f.db
 }
+
+// Export saves pending changes and calls fn for each word (combination) in the
+// database with its ham/spam counts, in order. The returned hams and spams are
+// the number of trained messages.
+func (f *Filter) Export(ctx context.Context, fn func(w Wordscore) error) (hams, spams uint32, rerr error) {
+	if err := f.Save(); err != nil {
+		return 0, 0, err
+	}
+	err := bstore.QueryDB[Wordscore](ctx, f.db).FilterNotEqual("Word", "-").ForEach(fn)
+	if err != nil {
+		return 0, 0, err
+	}
+	return f.hams, f.spams, nil
+}
+
+// Import adds counts of trained messages and of words, e.g. from an export of
+// another filter, to the counts in this filter. Words are added to the bloom
+// filter, which training uses to know which words are in the database. Call Save
+// or Close to store the changes.
+func (f *Filter) Import(ctx context.Context, hams, spams uint32, words []Wordscore) error {
+	if err := f.ensureBloom(); err != nil {
+		return err
+	}
+
+	var lwords []string
+	for _, w := range words {
+		if w.Word == "-" {
+			return fmt.Errorf("invalid word %q", w.Word)
+		}
+		if !f.bloom.Has(w.Word) {
+			f.bloom.Add(w.Word)
+		}
+		if _, ok := f.cache[w.Word]; !ok {
+			lwords = append(lwords, w.Word)
+		}
+	}
+	if err := f.loadCache(ctx, lwords); err != nil {
+		return err
+	}
+
+	f.modified = true
+	f.hams += hams
+	f.spams += spams
+	for _, w := range words {
+		c := f.cache[w.Word]
+		c.Ham += w.Ham
+		c.Spam += w.Spam
+		f.cache[w.Word] = c
+		f.changed[w.Word] = c
+	}
+	return nil
+}
+
+// Stats holds the number of trained messages and known words of a filter.
+type Stats struct {
+	Hams  uint32 // Number of messages trained as ham.
+	Spams uint32 // Number of messages trained as spam.
+	Words int    // Number of words (or word combinations) in the database.
+}
+
+// Stats returns the training statistics of the filter. Pending changes are not
+// reflected in the word count.
+func (f *Filter) Stats(ctx context.Context) (Stats, error) {
+	if f.closed {
+		return Stats{}, errClosed
+	}
+	n, err := bstore.QueryDB[Wordscore](ctx, f.db).FilterNotEqual("Word", "-").Count()
+	if err != nil {
+		return Stats{}, fmt.Errorf("counting words: %v", err)
+	}
+	return Stats{f.hams, f.spams, n}, nil
+}
@@ -202,3 +202,82 @@ func TestFilter(t *testing.T) {
 	err = f.Close()
 	tcheck(t, err, "close filter")
 }
+
+func TestStats(t *testing.T) {
+	log := mlog.New("junk", nil)
+	params := Params{Onegrams: true, MaxPower: 0.1, TopWords: 10}
+	dir := t.TempDir()
+	f, err := NewFilter(ctxbg, log, params, filepath.Join(dir, "filter.db"), filepath.Join(dir, "filter.bloom"))
+	tcheck(t, err, "new filter")
+	defer f.Close()
+
+	err = f.Train(ctxbg, true, map[string]struct{}{"hello": {}, "world": {}})
+	tcheck(t, err, "train ham")
+	err = f.Train(ctxbg, false, map[string]struct{}{"money": {}})
+	tcheck(t, err, "train spam")
+	err = f.Save()
+	tcheck(t, err, "save filter")
+
+	stats, err := f.Stats(ctxbg)
+	tcheck(t, err, "stats")
+	if stats != (Stats{Hams: 1, Spams: 1, Words: 3}) {
+		t.Fatalf("got stats %v, expected 1 ham, 1 spam, 3 words", stats)
+	}
+}
+
+func TestExportImport(t *testing.T) {
+	log := mlog.New("junk", nil)
+	params := Params{Onegrams: true, MaxPower: 0.1, TopWords: 10}
+	dir := t.TempDir()
+	f, err := NewFilter(ctxbg, log, params, filepath.Join(dir, "filter.db"), filepath.Join(dir, "filter.bloom"))
+	tcheck(t, err, "new filter")
+	defer f.Close()
+
+	err = f.Train(ctxbg, true, map[string]struct{}{"hello": {}, "world": {}})
+	tcheck(t, err, "train ham")
+	err = f.Train(ctxbg, false, map[string]struct{}{"money": {}, "world": {}})
+	tcheck(t, err, "train spam")
+
+	var words []Wordscore
+	hams, spams, err := f.Export(ctxbg, func(w Wordscore) error {
+		words = append(words, w)
+		return nil
+	})
+	tcheck(t, err, "export")
+	exp := []Wordscore{{"hello", 1, 0}, {"money", 0, 1}, {"world", 1, 1}}
+	if hams != 1 || spams != 1 || fmt.Sprint(words) != fmt.Sprint(exp) {
+		t.Fatalf("got hams %d, spams %d, words %v, expected 1, 1, %v", hams, spams, words, exp)
+	}
+
+	// Import into a filter with existing training adds the counts.
+	f2, err := NewFilter(ctxbg, log, params, filepath.Join(dir, "filter2.db"), filepath.Join(dir, "filter2.bloom"))
+	tcheck(t, err, "new filter")
+	defer f2.Close()
+	err = f2.Train(ctxbg, false, map[string]struct{}{"money": {}})
+	tcheck(t, err, "train spam")
+	err = f2.Save()
+	tcheck(t, err, "save filter")
+	err = f2.Import(ctxbg, hams, spams, words)
+	tcheck(t, err, "import")
+	err = f2.Save()
+	tcheck(t, err, "save filter")
+
+	// Training after import continues from the imported counts.
+	err = f2.Train(ctxbg, true, map[string]struct{}{"hello": {}})
+	tcheck(t, err, "train ham")
+	words = nil
+	hams, spams, err = f2.Export(ctxbg, func(w Wordscore) error {
+		words = append(words, w)
+		return nil
+	})
+	tcheck(t, err, "export")
+	exp = []Wordscore{{"hello", 2, 0}, {"money", 0, 2}, {"world", 1, 1}}
+	if hams != 2 || spams != 2 || fmt.Sprint(words) != fmt.Sprint(exp) {
+		t.Fatalf("got hams %d, spams %d, words %v, expected 2, 2, %v", hams, spams, words, exp)
+	}
+
+	err = f2.Import(ctxbg, 0, 0, []Wordscore{{"-", 1, 1}})
+	if err == nil {
+		t.Fatalf("import of message count word succeeded, expected error")
+	}
+}
@@ -0,0 +1,164 @@
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Minimal BER encoding and decoding, as used by LDAP, RFC 4511 section 5.1. Only
+// single-byte tags and definite lengths are used.
+
+var errBER = errors.New("malformed ber")
+
+// Maximum size of a message we read. Search results are for a single entry with a
+// few attributes.
+const maxMessageSize = 1024 * 1024
+
+// Tags for universal types.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x30
+	tagSet         = 0x31
+)
+
+// berElem is a decoded element, with its contents still encoded.
+type berElem struct {
+	tag  byte
+	data []byte
+}
+
+func berLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var buf []byte
+	for ; n > 0; n >>= 8 {
+		buf = append([]byte{byte(n)}, buf...)
+	}
+	return append([]byte{0x80 | byte(len(buf))}, buf...)
+}
+
+// berEncode returns an element with tag and the concatenated contents.
+func berEncode(tag byte, contents ...[]byte) []byte {
+	n := 0
+	for _, c := range contents {
+		n += len(c)
+	}
+	buf := append([]byte{tag}, berLength(n)...)
+	for _, c := range contents {
+		buf = append(buf, c...)
+	}
+	return buf
+}
+
+func berString(tag byte, s string) []byte {
+	return berEncode(tag, []byte(s))
+}
+
+func berInt(tag byte, v int64) []byte {
+	// Minimal two's complement, big endian.
+	buf := []byte{byte(v)}
+	for v >>= 8; (v != 0 || buf[0]&0x80 != 0) && (v != -1 || buf[0]&0x80 == 0); v >>= 8 {
+		buf = append([]byte{byte(v)}, buf...)
+	}
+	return berEncode(tag, buf)
+}
+
+func berBool(b bool) []byte {
+	if b {
+		return berEncode(tagBoolean, []byte{0xff})
+	}
+	return berEncode(tagBoolean, []byte{0})
+}
+
+// berParse parses the first element from buf, returning the remainder.
+func berParse(buf []byte) (elem berElem, rest []byte, rerr error) {
+	if len(buf) < 2 {
+		return berElem{}, nil, errBER
+	}
+	tag := buf[0]
+	if tag&0x1f == 0x1f {
+		return berElem{}, nil, fmt.Errorf("%w: multi-byte tag", errBER)
+	}
+	n := int(buf[1])
+	buf = buf[2:]
+	if n&0x80 != 0 {
+		nb := n & 0x7f
+		if nb == 0 || nb > 4 || len(buf) < nb {
+			return berElem{}, nil, fmt.Errorf("%w: bad length", errBER)
+		}
+		n = 0
+		for _, b := range buf[:nb] {
+			n = n<<8 | int(b)
+		}
+		buf = buf[nb:]
+	}
+	if n > len(buf) {
+		return berElem{}, nil, fmt.Errorf("%w: length beyond data", errBER)
+	}
+	return berElem{tag, buf[:n]}, buf[n:], nil
+}
+
+// berElems parses all elements in buf, e.g. the contents of a sequence.
+func berElems(buf []byte) ([]berElem, error) {
+	var l []berElem
+	for len(buf) > 0 {
+		e, rest, err := berParse(buf)
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, e)
+		buf = rest
+	}
+	return l, nil
+}
+
+// berRead reads a single element from r.
+func berRead(r *bufio.Reader) (berElem, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return berElem{}, err
+	}
+	n := int(hdr[1])
+	if n&0x80 != 0 {
+		lbuf := make([]byte, n&0x7f)
+		if len(lbuf) == 0 || len(lbuf) > 4 {
+			return berElem{}, fmt.Errorf("%w: bad length", errBER)
+		}
+		if _, err := io.ReadFull(r, lbuf); err != nil {
+			return berElem{}, err
+		}
+		n = 0
+		for _, b := range lbuf {
+			n = n<<8 | int(b)
+		}
+	}
+	if n > maxMessageSize {
+		return berElem{}, fmt.Errorf("%w: message too large", errBER)
+	}
+	data := make([]byte, n)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return berElem{}, err
+	}
+	return berElem{hdr[0], data}, nil
+}
+
+func (e berElem) int() (int64, error) {
+	if (e.tag != tagInteger && e.tag != tagEnumerated) || len(e.data) == 0 || len(e.data) > 8 {
+		return 0, fmt.Errorf("%w: bad integer", errBER)
+	}
+	v := int64(int8(e.data[0]))
+	for _, b := range e.data[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+func (e berElem) elems() ([]berElem, error) {
+	return berElems(e.data)
+}
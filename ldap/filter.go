@@ -0,0 +1,184 @@
+package ldap
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// EscapeFilter escapes s for use as value in a search filter, RFC 4515 section 3.
+func EscapeFilter(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		switch c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// filterParser parses the string representation of a search filter, RFC 4515,
+// into its BER encoding, RFC 4511 section 4.5.1.
+type filterParser struct {
+	s string
+	o int
+}
+
+// parseFilter returns the BER encoding of filter s. Extensible matches are not
+// supported.
+func parseFilter(s string) (buf []byte, rerr error) {
+	p := &filterParser{s: s}
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		err, ok := x.(filterError)
+		if !ok {
+			panic(x)
+		}
+		buf = nil
+		rerr = err
+	}()
+	buf = p.xfilter()
+	if p.o != len(p.s) {
+		p.xerrorf("leftover data")
+	}
+	return buf, nil
+}
+
+type filterError struct{ err error }
+
+func (e filterError) Error() string {
+	return e.err.Error()
+}
+
+func (p *filterParser) xerrorf(format string, args ...any) {
+	panic(filterError{fmt.Errorf("parsing filter %q at offset %d: %s", p.s, p.o, fmt.Sprintf(format, args...))})
+}
+
+func (p *filterParser) xtake(s string) {
+	if !strings.HasPrefix(p.s[p.o:], s) {
+		p.xerrorf("expected %q", s)
+	}
+	p.o += len(s)
+}
+
+func (p *filterParser) peek(s string) bool {
+	return strings.HasPrefix(p.s[p.o:], s)
+}
+
+func (p *filterParser) xfilter() []byte {
+	p.xtake("(")
+	var buf []byte
+	switch {
+	case p.peek("&"):
+		p.o++
+		buf = berEncode(0xa0, p.xfilterList()...)
+	case p.peek("|"):
+		p.o++
+		buf = berEncode(0xa1, p.xfilterList()...)
+	case p.peek("!"):
+		p.o++
+		buf = berEncode(0xa2, p.xfilter())
+	default:
+		buf = p.xitem()
+	}
+	p.xtake(")")
+	return buf
+}
+
+func (p *filterParser) xfilterList() [][]byte {
+	var l [][]byte
+	for p.peek("(") {
+		l = append(l, p.xfilter())
+	}
+	if len(l) == 0 {
+		p.xerrorf("empty filter list")
+	}
+	return l
+}
+
+func (p *filterParser) xitem() []byte {
+	n := strings.IndexAny(p.s[p.o:], "=()")
+	if n <= 0 || p.s[p.o+n] != '=' {
+		p.xerrorf("expected attribute and filter type")
+	}
+	attr := p.s[p.o : p.o+n]
+	p.o += n + 1
+	var tag byte
+	switch {
+	case strings.HasSuffix(attr, ">"):
+		tag = 0xa5 // greaterOrEqual
+	case strings.HasSuffix(attr, "<"):
+		tag = 0xa6 // lessOrEqual
+	case strings.HasSuffix(attr, "~"):
+		tag = 0xa8 // approxMatch
+	case strings.HasSuffix(attr, ":"):
+		p.xerrorf("extensible match not supported")
+	}
+	if tag != 0 {
+		attr = attr[:len(attr)-1]
+	}
+	if attr == "" {
+		p.xerrorf("empty attribute")
+	}
+
+	// Value, split in parts on unescaped asterisks, for substring matches.
+	parts := [][]byte{nil}
+	for p.o < len(p.s) && p.s[p.o] != ')' {
+		c := p.s[p.o]
+		p.o++
+		switch c {
+		case '*':
+			parts = append(parts, nil)
+			continue
+		case '(':
+			p.xerrorf("unescaped parenthesis in value")
+		case '\\':
+			if p.o+2 > len(p.s) {
+				p.xerrorf("short escape")
+			}
+			v, err := strconv.ParseUint(p.s[p.o:p.o+2], 16, 8)
+			if err != nil {
+				p.xerrorf("bad escape")
+			}
+			c = byte(v)
+			p.o += 2
+		}
+		parts[len(parts)-1] = append(parts[len(parts)-1], c)
+	}
+
+	if len(parts) == 1 {
+		if tag == 0 {
+			tag = 0xa3 // equalityMatch
+		}
+		return berEncode(tag, berString(tagOctetString, attr), berEncode(tagOctetString, parts[0]))
+	}
+	if tag != 0 {
+		p.xerrorf("asterisk only allowed with equality filter")
+	}
+	if len(parts) == 2 && len(parts[0]) == 0 && len(parts[1]) == 0 {
+		return berString(0x87, attr) // present
+	}
+	var subs [][]byte
+	for i, s := range parts {
+		if len(s) == 0 {
+			if i > 0 && i < len(parts)-1 {
+				p.xerrorf("empty substring")
+			}
+			continue
+		}
+		var t byte = 0x81 // any
+		if i == 0 {
+			t = 0x80 // initial
+		} else if i == len(parts)-1 {
+			t = 0x82 // final
+		}
+		subs = append(subs, berEncode(t, s))
+	}
+	return berEncode(0xa4, berString(tagOctetString, attr), berEncode(tagSequence, subs...))
+}
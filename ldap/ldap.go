@@ -0,0 +1,347 @@
+// Package ldap verifies passwords with an LDAP directory, RFC 4511.
+//
+// Users are searched for with a filter, after which the password is verified by
+// binding as the user entry (simple authentication, RFC 4513 section 5.1).
+// Attributes of the entry map the user to a mox account, which can be created on
+// first login. The Authenticator is used as store.ExternalAuth.
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/admin"
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// Result codes, RFC 4511 section 4.1.9.
+const (
+	resultSuccess            = 0
+	resultInvalidCredentials = 49
+)
+
+// Timeout for an authentication, with connection, search and binds.
+const timeout = 30 * time.Second
+
+// For tests.
+var tlsConfig *tls.Config
+
+// ResultError is an unsuccessful result from the LDAP server.
+type ResultError struct {
+	Code    int64
+	Message string
+}
+
+func (e ResultError) Error() string {
+	return fmt.Sprintf("ldap result code %d: %s", e.Code, e.Message)
+}
+
+// conn is a connection to an LDAP server. Operations are done one at a time.
+type conn struct {
+	conn  net.Conn
+	br    *bufio.Reader
+	msgID int64
+}
+
+func dial(ctx context.Context, c config.LDAP) (*conn, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing url: %v", err)
+	}
+	host := u.Host
+	if u.Port() == "" {
+		if u.Scheme == "ldaps" {
+			host = net.JoinHostPort(u.Hostname(), "636")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "389")
+		}
+	}
+	tlsConf := tlsConfig
+	if tlsConf == nil {
+		tlsConf = &tls.Config{ServerName: u.Hostname(), MinVersion: tls.VersionTLS12}
+	}
+
+	var nc net.Conn
+	var d net.Dialer
+	if u.Scheme == "ldaps" {
+		td := tls.Dialer{NetDialer: &d, Config: tlsConf}
+		nc, err = td.DialContext(ctx, "tcp", host)
+	} else {
+		nc, err = d.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("dial: %v", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		nc.SetDeadline(deadline)
+	}
+	lc := &conn{conn: nc, br: bufio.NewReader(nc)}
+
+	if c.StartTLS {
+		// Extended request with the StartTLS OID, RFC 4511 section 4.14.
+		if _, err := lc.request(berEncode(0x77, berString(0x80, "1.3.6.1.4.1.1466.20037")), 0x78); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("starttls: %w", err)
+		}
+		tc := tls.Client(nc, tlsConf)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("tls handshake after starttls: %v", err)
+		}
+		lc.conn = tc
+		lc.br = bufio.NewReader(tc)
+	}
+	return lc, nil
+}
+
+func (c *conn) close() {
+	// Unbind request, RFC 4511 section 4.3, no response.
+	c.msgID++
+	c.conn.Write(berEncode(tagSequence, berInt(tagInteger, c.msgID), []byte{0x42, 0x00}))
+	c.conn.Close()
+}
+
+// request sends op and returns the response with tag respTag, checking its result
+// code. For searches, the entries before the final response are returned too.
+func (c *conn) request(op []byte, respTag byte) ([]berElem, error) {
+	c.msgID++
+	if _, err := c.conn.Write(berEncode(tagSequence, berInt(tagInteger, c.msgID), op)); err != nil {
+		return nil, fmt.Errorf("write: %v", err)
+	}
+	var l []berElem
+	for {
+		msg, err := berRead(c.br)
+		if err != nil {
+			return nil, fmt.Errorf("read: %w", err)
+		}
+		elems, err := msg.elems()
+		if err != nil {
+			return nil, err
+		}
+		if msg.tag != tagSequence || len(elems) < 2 {
+			return nil, fmt.Errorf("%w: bad message", errBER)
+		}
+		if id, err := elems[0].int(); err != nil {
+			return nil, err
+		} else if id == 0 {
+			// Unsolicited notification, e.g. notice of disconnection.
+			return nil, fmt.Errorf("unsolicited notification from server")
+		} else if id != c.msgID {
+			return nil, fmt.Errorf("response for message id %d, expected %d", id, c.msgID)
+		}
+		resp := elems[1]
+		if resp.tag != respTag {
+			l = append(l, resp)
+			continue
+		}
+		// LDAPResult, RFC 4511 section 4.1.9.
+		result, err := resp.elems()
+		if err != nil {
+			return nil, err
+		}
+		if len(result) < 3 {
+			return nil, fmt.Errorf("%w: short result", errBER)
+		}
+		code, err := result[0].int()
+		if err != nil {
+			return nil, err
+		}
+		if code != resultSuccess {
+			return nil, ResultError{code, string(result[2].data)}
+		}
+		return l, nil
+	}
+}
+
+// bind does a simple bind, RFC 4511 section 4.2.
+func (c *conn) bind(dn, password string) error {
+	_, err := c.request(berEncode(0x60, berInt(tagInteger, 3), berString(tagOctetString, dn), berString(0x80, password)), 0x61)
+	return err
+}
+
+// entry is a search result.
+type entry struct {
+	dn    string
+	attrs map[string][]string // Keys are lower case.
+}
+
+// search does a subtree search, RFC 4511 section 4.5.
+func (c *conn) search(baseDN string, filter []byte, attrs []string) ([]entry, error) {
+	var attrList [][]byte
+	for _, a := range attrs {
+		attrList = append(attrList, berString(tagOctetString, a))
+	}
+	op := berEncode(0x63,
+		berString(tagOctetString, baseDN),
+		berInt(tagEnumerated, 2), // wholeSubtree
+		berInt(tagEnumerated, 0), // neverDerefAliases
+		berInt(tagInteger, 2),    // sizeLimit, we only want one entry.
+		berInt(tagInteger, int64(timeout/time.Second)),
+		berBool(false), // typesOnly
+		filter,
+		berEncode(tagSequence, attrList...),
+	)
+	resps, err := c.request(op, 0x65)
+	if err != nil {
+		return nil, err
+	}
+	var l []entry
+	for _, resp := range resps {
+		if resp.tag != 0x64 {
+			// E.g. search result references, which we don't follow.
+			continue
+		}
+		elems, err := resp.elems()
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) != 2 {
+			return nil, fmt.Errorf("%w: bad search result entry", errBER)
+		}
+		e := entry{string(elems[0].data), map[string][]string{}}
+		attrs, err := elems[1].elems()
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range attrs {
+			t, err := a.elems()
+			if err != nil {
+				return nil, err
+			}
+			if len(t) != 2 {
+				return nil, fmt.Errorf("%w: bad attribute", errBER)
+			}
+			vals, err := t[1].elems()
+			if err != nil {
+				return nil, err
+			}
+			k := strings.ToLower(string(t[0].data))
+			for _, v := range vals {
+				e.attrs[k] = append(e.attrs[k], string(v.data))
+			}
+		}
+		l = append(l, e)
+	}
+	return l, nil
+}
+
+func (e entry) attr(name string) string {
+	if l := e.attrs[strings.ToLower(name)]; len(l) > 0 {
+		return l[0]
+	}
+	return ""
+}
+
+// Authenticator verifies passwords with the LDAP directory configured in
+// mox.conf. It implements store.Authenticator.
+type Authenticator struct{}
+
+var _ store.Authenticator = Authenticator{}
+
+// Authenticate searches the directory for login, verifies password by binding as
+// the user entry, and returns the name of the account from the account attribute.
+// If the account doesn't exist and auto provisioning is enabled, it is created.
+func (Authenticator) Authenticate(ctx context.Context, log mlog.Log, login, password string) (accountName string, rerr error) {
+	lc := mox.Conf.Static.LDAP
+	if lc == nil {
+		return "", store.ErrUnknownCredentials
+	}
+	// An empty password is an unauthenticated bind, which servers may allow, RFC
+	// 4513 section 5.1.2.
+	if password == "" {
+		return "", store.ErrUnknownCredentials
+	}
+
+	defer func() {
+		if rerr != nil && !errors.Is(rerr, store.ErrUnknownCredentials) {
+			log.Errorx("ldap authentication", rerr, slog.String("login", login))
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	filter := lc.Filter
+	if filter == "" {
+		filter = "(mail=%s)"
+	}
+	filterBuf, err := parseFilter(strings.Replace(filter, "%s", EscapeFilter(login), 1))
+	if err != nil {
+		return "", err
+	}
+	accountAttr := lc.AccountAttribute
+	if accountAttr == "" {
+		accountAttr = "uid"
+	}
+	addressAttr := lc.AddressAttribute
+	if addressAttr == "" {
+		addressAttr = "mail"
+	}
+
+	c, err := dial(ctx, *lc)
+	if err != nil {
+		return "", fmt.Errorf("connecting to ldap server: %w", err)
+	}
+	defer c.close()
+
+	if lc.BindDN != "" {
+		if err := c.bind(lc.BindDN, lc.BindPassword); err != nil {
+			return "", fmt.Errorf("bind for search: %w", err)
+		}
+	}
+	entries, err := c.search(lc.BaseDN, filterBuf, []string{accountAttr, addressAttr})
+	if err != nil {
+		return "", fmt.Errorf("search: %w", err)
+	}
+	if len(entries) == 0 {
+		return "", store.ErrUnknownCredentials
+	} else if len(entries) > 1 {
+		return "", fmt.Errorf("search for login matched multiple entries")
+	}
+	e := entries[0]
+
+	var re ResultError
+	if err := c.bind(e.dn, password); errors.As(err, &re) && re.Code == resultInvalidCredentials {
+		return "", store.ErrUnknownCredentials
+	} else if err != nil {
+		return "", fmt.Errorf("bind as user: %w", err)
+	}
+
+	accountName = e.attr(accountAttr)
+	if accountName == "" {
+		return "", fmt.Errorf("user entry %q has no account attribute %q", e.dn, accountAttr)
+	}
+	if _, ok := mox.Conf.Account(accountName); ok {
+		return accountName, nil
+	}
+	if !lc.AutoProvision {
+		log.Info("no account for ldap user, and auto provisioning disabled", slog.String("login", login), slog.String("account", accountName))
+		return "", store.ErrUnknownCredentials
+	}
+
+	address := e.attr(addressAttr)
+	if address == "" {
+		return "", fmt.Errorf("user entry %q has no address attribute %q for provisioning account", e.dn, addressAttr)
+	}
+	if _, err := smtp.ParseAddress(address); err != nil {
+		return "", fmt.Errorf("parsing address %q of user entry for provisioning account: %v", address, err)
+	}
+	if err := admin.AccountAdd(ctx, accountName, address); err != nil {
+		return "", fmt.Errorf("provisioning account: %w", err)
+	}
+	log.Info("account provisioned for ldap user", slog.String("account", accountName), slog.String("address", address))
+	store.AuditAdd(ctx, log, store.AuditEvent{Kind: store.AuditAccount, Account: accountName, Protocol: "ldap", Action: "provision", Result: "ok", Details: address})
+	return accountName, nil
+}
@@ -0,0 +1,230 @@
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+type testEntry struct {
+	dn, password, uid, mail string
+}
+
+// serve handles a connection as a minimal LDAP server.
+func serve(t *testing.T, nc net.Conn, filter string, entries []testEntry) {
+	defer nc.Close()
+	br := bufio.NewReader(nc)
+	result := func(id int64, tag byte, code int64) {
+		nc.Write(berEncode(tagSequence, berInt(tagInteger, id), berEncode(tag, berInt(tagEnumerated, code), berString(tagOctetString, ""), berString(tagOctetString, ""))))
+	}
+	for {
+		msg, err := berRead(br)
+		if err != nil {
+			return
+		}
+		elems, err := msg.elems()
+		if err != nil || len(elems) < 2 {
+			t.Errorf("bad message: %v", err)
+			return
+		}
+		id, _ := elems[0].int()
+		op := elems[1]
+		fields, _ := op.elems()
+		switch op.tag {
+		case 0x42:
+			return
+		case 0x60:
+			dn, password := string(fields[1].data), string(fields[2].data)
+			code := int64(resultInvalidCredentials)
+			if dn == "cn=search" && password == "search" {
+				code = resultSuccess
+			}
+			for _, e := range entries {
+				if dn == e.dn && password == e.password {
+					code = resultSuccess
+				}
+			}
+			result(id, 0x61, code)
+		case 0x63:
+			if string(fields[0].data) != "dc=mox,dc=example" {
+				result(id, 0x65, 32) // noSuchObject
+				continue
+			}
+			for _, e := range entries {
+				buf, err := parseFilter(strings.Replace(filter, "%s", EscapeFilter(e.mail), 1))
+				tcheck(t, err, "parse filter")
+				if !bytes.Equal(buf, berEncode(fields[6].tag, fields[6].data)) {
+					continue
+				}
+				attrs := berEncode(tagSequence,
+					berEncode(tagSequence, berString(tagOctetString, "uid"), berEncode(tagSet, berString(tagOctetString, e.uid))),
+					berEncode(tagSequence, berString(tagOctetString, "mail"), berEncode(tagSet, berString(tagOctetString, e.mail))),
+				)
+				nc.Write(berEncode(tagSequence, berInt(tagInteger, id), berEncode(0x64, berString(tagOctetString, e.dn), attrs)))
+			}
+			result(id, 0x65, resultSuccess)
+		default:
+			result(id, op.tag+1, 2) // protocolError
+		}
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	log := mlog.New("ldap", nil)
+
+	// Account provisioning writes domains.conf, so work on a copy.
+	dir := t.TempDir()
+	for _, name := range []string{"mox.conf", "domains.conf"} {
+		buf, err := os.ReadFile(filepath.FromSlash("../testdata/store/" + name))
+		tcheck(t, err, "read config")
+		err = os.WriteFile(filepath.Join(dir, name), buf, 0660)
+		tcheck(t, err, "write config")
+	}
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	const filter = "(&(objectClass=person)(mail=%s))"
+	entries := []testEntry{
+		{"uid=mjl,dc=mox,dc=example", "mjlpass", "mjl", "mjl@mox.example"},
+		{"uid=new,dc=mox,dc=example", "newpass", "new", "new@mox.example"},
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tcheck(t, err, "listen")
+	defer ln.Close()
+	go func() {
+		for {
+			nc, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serve(t, nc, filter, entries)
+		}
+	}()
+
+	mox.Conf.Static.LDAP = &config.LDAP{
+		URL:          "ldap://" + ln.Addr().String(),
+		BindDN:       "cn=search",
+		BindPassword: "search",
+		BaseDN:       "dc=mox,dc=example",
+		Filter:       filter,
+	}
+	defer func() {
+		mox.Conf.Static.LDAP = nil
+	}()
+
+	var a Authenticator
+	accName, err := a.Authenticate(ctxbg, log, "mjl@mox.example", "mjlpass")
+	tcheck(t, err, "authenticate")
+	if accName != "mjl" {
+		t.Fatalf("got account %q, expected mjl", accName)
+	}
+
+	for _, tc := range []struct{ login, password string }{
+		{"mjl@mox.example", "bogus"},
+		{"mjl@mox.example", ""},
+		{"bogus@mox.example", "mjlpass"},
+		{"*@mox.example", "mjlpass"},   // Must be escaped, not match all.
+		{"new@mox.example", "newpass"}, // No auto provisioning.
+	} {
+		if _, err := a.Authenticate(ctxbg, log, tc.login, tc.password); !errors.Is(err, store.ErrUnknownCredentials) {
+			t.Fatalf("authenticate %q: got err %v, expected ErrUnknownCredentials", tc.login, err)
+		}
+	}
+
+	// Bad search credentials.
+	mox.Conf.Static.LDAP.BindPassword = "bogus"
+	if _, err := a.Authenticate(ctxbg, log, "mjl@mox.example", "mjlpass"); err == nil || errors.Is(err, store.ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected error for failing search bind", err)
+	}
+	mox.Conf.Static.LDAP.BindPassword = "search"
+
+	mox.Conf.Static.LDAP.AutoProvision = true
+	accName, err = a.Authenticate(ctxbg, log, "new@mox.example", "newpass")
+	tcheck(t, err, "authenticate with provisioning")
+	if accName != "new" {
+		t.Fatalf("got account %q, expected new", accName)
+	}
+	if _, ok := mox.Conf.Account("new"); !ok {
+		t.Fatalf("account not provisioned")
+	}
+	l, err := store.AuditList(ctxbg, store.AuditFilter{Kind: store.AuditAccount, Account: "new"}, 0)
+	tcheck(t, err, "list audit events")
+	if len(l) != 1 || l[0].Action != "provision" {
+		t.Fatalf("got audit events %v, expected provisioning", l)
+	}
+	accName, err = a.Authenticate(ctxbg, log, "new@mox.example", "newpass")
+	tcheck(t, err, "authenticate after provisioning")
+	if accName != "new" {
+		t.Fatalf("got account %q, expected new", accName)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	valid := []string{
+		"(mail=mjl@mox.example)",
+		"(&(objectClass=person)(|(mail=mjl@mox.example)(uid=mjl)))",
+		"(!(uid=mjl))",
+		"(uid=*)",
+		"(cn=*m*j*l)",
+		"(cn=m*)",
+		"(uidNumber>=1000)",
+		"(cn~=mjl)",
+		"(cn=a\\2ab)",
+	}
+	for _, s := range valid {
+		_, err := parseFilter(s)
+		tcheck(t, err, "parse filter "+s)
+	}
+	invalid := []string{
+		"",
+		"mail=mjl",
+		"(mail=mjl",
+		"(mail=mjl))",
+		"(&)",
+		"(=mjl)",
+		"(cn:dn:=mjl)",
+		"(cn>=m*)",
+		"(cn=a**b)",
+		"(cn=a\\zz)",
+		"(cn=a(b)",
+	}
+	for _, s := range invalid {
+		if _, err := parseFilter(s); err == nil {
+			t.Fatalf("parse filter %q: expected error", s)
+		}
+	}
+
+	buf, err := parseFilter("(cn=" + EscapeFilter("a*(b)\\") + ")")
+	tcheck(t, err, "parse filter")
+	expect := berEncode(0xa3, berString(tagOctetString, "cn"), berString(tagOctetString, "a*(b)\\"))
+	if !bytes.Equal(buf, expect) {
+		t.Fatalf("got %x, expected %x", buf, expect)
+	}
+}
@@ -357,6 +357,18 @@ func writeLocalConfig(log mlog.Log, dir, ip string) (rerr error) {
 	local.WebAPIHTTPS.Enabled = true
 	local.WebAPIHTTPS.Port = 1443
 	local.WebAPIHTTPS.Path = "/webapi/"
+	local.CalDAVHTTP.Enabled = true
+	local.CalDAVHTTP.Port = 1080
+	local.CalDAVHTTP.Path = "/caldav/"
+	local.CalDAVHTTPS.Enabled = true
+	local.CalDAVHTTPS.Port = 1443
+	local.CalDAVHTTPS.Path = "/caldav/"
+	local.CardDAVHTTP.Enabled = true
+	local.CardDAVHTTP.Port = 1080
+	local.CardDAVHTTP.Path = "/carddav/"
+	local.CardDAVHTTPS.Enabled = true
+	local.CardDAVHTTPS.Port = 1443
+	local.CardDAVHTTPS.Path = "/carddav/"
 	local.AdminHTTP.Enabled = true
 	local.AdminHTTP.Port = 1080
 	local.AdminHTTPS.Enabled = true
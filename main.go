@@ -106,9 +106,14 @@ var commands = []struct {
 	{"setaccountpassword", cmdSetaccountpassword},
 	{"setadminpassword", cmdSetadminpassword},
 	{"loglevels", cmdLoglevels},
+	{"auditlog export", cmdAuditlogExport},
+	{"provisioning token list", cmdProvisioningTokenList},
+	{"provisioning token add", cmdProvisioningTokenAdd},
+	{"provisioning token rm", cmdProvisioningTokenRemove},
 	{"queue holdrules list", cmdQueueHoldrulesList},
 	{"queue holdrules add", cmdQueueHoldrulesAdd},
 	{"queue holdrules remove", cmdQueueHoldrulesRemove},
+	{"queue holdrules resume", cmdQueueHoldrulesResume},
 	{"queue list", cmdQueueList},
 	{"queue hold", cmdQueueHold},
 	{"queue unhold", cmdQueueUnhold},
@@ -120,6 +125,12 @@ var commands = []struct {
 	{"queue dump", cmdQueueDump},
 	{"queue retired list", cmdQueueRetiredList},
 	{"queue retired print", cmdQueueRetiredPrint},
+	{"queue dead list", cmdQueueDeadList},
+	{"queue dead print", cmdQueueDeadPrint},
+	{"queue dead dump", cmdQueueDeadDump},
+	{"queue dead recipient", cmdQueueDeadRecipient},
+	{"queue dead requeue", cmdQueueDeadRequeue},
+	{"queue dead remove", cmdQueueDeadRemove},
 	{"queue suppress list", cmdQueueSuppressList},
 	{"queue suppress add", cmdQueueSuppressAdd},
 	{"queue suppress remove", cmdQueueSuppressRemove},
@@ -134,6 +145,8 @@ var commands = []struct {
 	{"import mbox", cmdImportMbox},
 	{"export maildir", cmdExportMaildir},
 	{"export mbox", cmdExportMbox},
+	{"junk export", cmdJunkExport},
+	{"junk import", cmdJunkImport},
 	{"localserve", cmdLocalserve},
 	{"help", cmdHelp},
 	{"backup", cmdBackup},
@@ -143,13 +156,20 @@ var commands = []struct {
 	{"config test", cmdConfigTest},
 	{"config dnscheck", cmdConfigDNSCheck},
 	{"config dnsrecords", cmdConfigDNSRecords},
+	{"config dnsprovision", cmdConfigDNSProvision},
 	{"config describe-domains", cmdConfigDescribeDomains},
 	{"config describe-static", cmdConfigDescribeStatic},
 	{"config account list", cmdConfigAccountList},
 	{"config account add", cmdConfigAccountAdd},
+	{"config account import", cmdConfigAccountImport},
 	{"config account rm", cmdConfigAccountRemove},
 	{"config account disable", cmdConfigAccountDisable},
 	{"config account enable", cmdConfigAccountEnable},
+	{"config adminuser list", cmdConfigAdminuserList},
+	{"config adminuser add", cmdConfigAdminuserAdd},
+	{"config adminuser update", cmdConfigAdminuserUpdate},
+	{"config adminuser setpassword", cmdConfigAdminuserSetpassword},
+	{"config adminuser rm", cmdConfigAdminuserRemove},
 	{"config address add", cmdConfigAddressAdd},
 	{"config address rm", cmdConfigAddressRemove},
 	{"config domain add", cmdConfigDomainAdd},
@@ -161,6 +181,7 @@ var commands = []struct {
 	{"config tlspubkey add", cmdConfigTlspubkeyAdd},
 	{"config tlspubkey rm", cmdConfigTlspubkeyRemove},
 	{"config tlspubkey gen", cmdConfigTlspubkeyGen},
+	{"config tlscerts reload", cmdConfigTLSCertsReload},
 	{"config alias list", cmdConfigAliasList},
 	{"config alias print", cmdConfigAliasPrint},
 	{"config alias add", cmdConfigAliasAdd},
@@ -972,6 +993,60 @@ func ctlcmdConfigAccountAdd(ctl *ctl, account, address string) {
 	fmt.Printf("account added, set a password with \"mox setaccountpassword %s\"\n", account)
 }
 
+func cmdConfigAccountImport(c *cmd) {
+	c.params = "[-dryrun] [-requirechange] file.csv|file.json"
+	c.help = `Add multiple accounts from a CSV or JSON file and reload the configuration.
+
+Each CSV line has fields: account name, address, full name, additional
+addresses (space-separated, "@domain" for a catchall address), quota message
+size in bytes, password. All fields after the address are optional. A first
+line starting with field "name" is skipped as header. A JSON file holds an
+array of objects with fields Name, Address, FullName, Addresses,
+QuotaMessageSize and Password.
+
+Either all accounts are added, or none. For each account, a line with the
+account name, address and initial password is printed. Passwords are generated
+for accounts without password. With -requirechange, the password must be
+changed through the account web interface before other logins work.
+`
+	var dryRun, requireChange bool
+	c.flag.BoolVar(&dryRun, "dryrun", false, "only check that the accounts can be added")
+	c.flag.BoolVar(&requireChange, "requirechange", false, "require accounts to change their initial password")
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+
+	buf, err := os.ReadFile(args[0])
+	xcheckf(err, "reading file")
+	accounts, err := admin.ParseAccountImports(buf)
+	xcheckf(err, "parsing accounts")
+
+	mustLoadConfig()
+	ctlcmdConfigAccountImport(xctl(), accounts, dryRun, requireChange)
+}
+
+func ctlcmdConfigAccountImport(ctl *ctl, accounts []admin.AccountImport, dryRun, requireChange bool) {
+	ctl.xwrite("accountimport")
+	xctlwriteJSON(ctl, accounts)
+	ctl.xwrite(fmt.Sprintf("%v", dryRun))
+	ctl.xwrite(fmt.Sprintf("%v", requireChange))
+	ctl.xreadok()
+	var results []admin.AccountImportResult
+	xparseJSON(ctl, ctl.xread(), &results)
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Printf("%s\t%s\terror: %s\n", r.Name, r.Address, r.Error)
+		} else if !dryRun {
+			fmt.Printf("%s\t%s\t%s\n", r.Name, r.Address, r.Password)
+		}
+	}
+	ctl.xreadok()
+	if dryRun {
+		fmt.Printf("%d accounts can be added\n", len(results))
+	}
+}
+
 func cmdConfigAccountRemove(c *cmd) {
 	c.params = "account"
 	c.help = `Remove an account and reload the configuration.
@@ -1064,6 +1139,138 @@ func ctlcmdConfigAccountDisabled(ctl *ctl, account, loginDisabled string) {
 	ctl.xreadok()
 }
 
+func cmdConfigAdminuserList(c *cmd) {
+	c.help = `List admin users for the admin web interface.
+
+Each admin user is printed on a line, with tab-separated role and domains.
+`
+	args := c.Parse()
+	if len(args) != 0 {
+		c.Usage()
+	}
+
+	mustLoadConfig()
+	ctlcmdConfigAdminuserList(xctl())
+}
+
+func ctlcmdConfigAdminuserList(ctl *ctl) {
+	ctl.xwrite("adminuserlist")
+	ctl.xreadok()
+	ctl.xstreamto(os.Stdout)
+}
+
+func cmdConfigAdminuserAdd(c *cmd) {
+	c.params = "[-domains domain1,...] name admin|domainadmin|auditor"
+	c.help = `Add an admin user for the admin web interface.
+
+Role admin has full access. Role domainadmin can only manage the domains
+specified with -domains, along with their accounts, addresses and aliases. Role
+auditor has read-only access.
+
+The password is read from stdin.
+
+Roles only apply to the admin web interface. Commands through the ctl socket,
+like this one, always have full access: The socket is in the data directory, so
+anyone who can connect to it can also read the configuration files, with
+secrets, and the databases directly. Roles would not protect anything there.
+`
+	var domains string
+	c.flag.StringVar(&domains, "domains", "", "comma-separated domains, for role domainadmin")
+	args := c.Parse()
+	if len(args) != 2 {
+		c.Usage()
+	}
+
+	mustLoadConfig()
+	pw := xreadpassword()
+	ctlcmdConfigAdminuserAdd(xctl(), args[0], args[1], splitDomains(domains), pw)
+}
+
+func splitDomains(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, ",")
+}
+
+func ctlcmdConfigAdminuserAdd(ctl *ctl, name, role string, domains []string, password string) {
+	ctl.xwrite("adminuseradd")
+	ctl.xwrite(name)
+	ctl.xwrite(role)
+	xctlwriteJSON(ctl, domains)
+	ctl.xwrite(password)
+	ctl.xreadok()
+	fmt.Println("admin user added")
+}
+
+func cmdConfigAdminuserUpdate(c *cmd) {
+	c.params = "[-domains domain1,...] name admin|domainadmin|auditor"
+	c.help = `Change the role and domains of an admin user.`
+	var domains string
+	c.flag.StringVar(&domains, "domains", "", "comma-separated domains, for role domainadmin")
+	args := c.Parse()
+	if len(args) != 2 {
+		c.Usage()
+	}
+
+	mustLoadConfig()
+	ctlcmdConfigAdminuserUpdate(xctl(), args[0], args[1], splitDomains(domains))
+}
+
+func ctlcmdConfigAdminuserUpdate(ctl *ctl, name, role string, domains []string) {
+	ctl.xwrite("adminuserupdate")
+	ctl.xwrite(name)
+	ctl.xwrite(role)
+	xctlwriteJSON(ctl, domains)
+	ctl.xreadok()
+	fmt.Println("admin user updated")
+}
+
+func cmdConfigAdminuserSetpassword(c *cmd) {
+	c.params = "name"
+	c.help = `Set a new password for an admin user.
+
+The password is read from stdin.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+
+	mustLoadConfig()
+	pw := xreadpassword()
+	ctlcmdConfigAdminuserSetpassword(xctl(), args[0], pw)
+}
+
+func ctlcmdConfigAdminuserSetpassword(ctl *ctl, name, password string) {
+	ctl.xwrite("adminusersetpassword")
+	ctl.xwrite(name)
+	ctl.xwrite(password)
+	ctl.xreadok()
+}
+
+func cmdConfigAdminuserRemove(c *cmd) {
+	c.params = "name"
+	c.help = `Remove an admin user.
+
+Active sessions of the admin user are no longer valid.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+
+	mustLoadConfig()
+	ctlcmdConfigAdminuserRemove(xctl(), args[0])
+}
+
+func ctlcmdConfigAdminuserRemove(ctl *ctl, name string) {
+	ctl.xwrite("adminuserrm")
+	ctl.xwrite(name)
+	ctl.xreadok()
+	fmt.Println("admin user removed")
+}
+
 func cmdConfigTlspubkeyList(c *cmd) {
 	c.params = "[account]"
 	c.help = `List TLS public keys for TLS client certificate authentication.
@@ -1196,6 +1403,32 @@ func ctlcmdConfigTlspubkeyRemove(ctl *ctl, fingerprint string) {
 	ctl.xreadok()
 }
 
+func cmdConfigTLSCertsReload(c *cmd) {
+	c.help = `Reload TLS certificates of listeners from files and certificate sources.
+
+Listeners with KeyCerts or CertSources in their TLS config get their keys and
+certificates loaded again, e.g. after an external ACME client has renewed
+certificates or they were updated in Vault. If loading fails for a listener, it
+keeps its current certificates. Sending a SIGHUP to the mox process also reloads
+certificates.
+
+Names of listeners with reloaded certificates are printed.
+`
+	args := c.Parse()
+	if len(args) != 0 {
+		c.Usage()
+	}
+
+	mustLoadConfig()
+	ctlcmdConfigTLSCertsReload(xctl())
+}
+
+func ctlcmdConfigTLSCertsReload(ctl *ctl) {
+	ctl.xwrite("tlscertsreload")
+	ctl.xreadok()
+	ctl.xstreamto(os.Stdout)
+}
+
 func cmdConfigTlspubkeyGen(c *cmd) {
 	c.params = "stem"
 	c.help = `Generate an ed25519 private key and minimal certificate for use a TLS public key and write to files starting with stem.
@@ -1351,6 +1584,33 @@ configured.
 	fmt.Print(strings.Join(records, "\n") + "\n")
 }
 
+func cmdConfigDNSProvision(c *cmd) {
+	c.params = "domain"
+	c.help = `Create or update DNS records for the domain through its DNS provider.
+
+The domain must have DNSProvisioning configured, with a provider from
+DNSProviders in mox.conf. DKIM records for all selectors, the SPF record, the
+MTA-STS record and TLSA records for the mail host are created or updated, but
+only if they are in the DNS zone of the domain. Other TXT records at the domain,
+besides SPF, are left alone. A running mox also does this periodically, along
+with DKIM key rotation if configured.
+
+The changed records are printed.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+
+	d := xparseDomain(args[0], "domain")
+	mustLoadConfig()
+	changed, err := admin.DNSProvision(context.Background(), d)
+	xcheckf(err, "provisioning dns records")
+	for _, s := range changed {
+		fmt.Println(s)
+	}
+}
+
 func cmdConfigDNSCheck(c *cmd) {
 	c.params = "domain"
 	c.help = "Check the DNS records with the configuration for the domain, and print any errors/warnings."
@@ -1669,6 +1929,108 @@ func ctlcmdSetLoglevels(ctl *ctl, pkg, level string) {
 	ctl.xreadok()
 }
 
+func cmdAuditlogExport(c *cmd) {
+	c.params = "[-format json|syslog] [-kind kind] [-account account] [-since duration]"
+	c.help = `Export events from the audit log, oldest first.
+
+The audit log records security-relevant events: logins and failed logins,
+password and settings changes, configuration changes by admins, accounts
+added and removed, exports and queue changes.
+
+With format json (default), each event is printed as a JSON object on a line.
+With format syslog, each event is printed as a syslog message as in RFC 5424,
+with facility authpriv, for feeding to a log collector.
+
+Kinds: login, password, settings, config, account, export, queue.
+`
+	var format, since string
+	var filter store.AuditFilter
+	c.flag.StringVar(&format, "format", "json", "json or syslog")
+	c.flag.StringVar(&filter.Kind, "kind", "", "only export events of this kind")
+	c.flag.StringVar(&filter.Account, "account", "", "only export events for this account")
+	c.flag.StringVar(&since, "since", "", "only export events in this period before now, e.g. 24h")
+	args := c.Parse()
+	if len(args) != 0 || format != "json" && format != "syslog" {
+		c.Usage()
+	}
+	if since != "" {
+		d, err := time.ParseDuration(since)
+		xcheckf(err, "parsing duration")
+		t := time.Now().Add(-d)
+		filter.Start = &t
+	}
+	mustLoadConfig()
+	ctlcmdAuditlogExport(xctl(), format, filter)
+}
+
+func ctlcmdAuditlogExport(ctl *ctl, format string, filter store.AuditFilter) {
+	ctl.xwrite("auditlogexport")
+	ctl.xwrite(format)
+	xctlwriteJSON(ctl, filter)
+	ctl.xreadok()
+	ctl.xstreamto(os.Stdout)
+}
+
+func cmdProvisioningTokenList(c *cmd) {
+	c.help = `List tokens for the provisioning API.
+
+Each token is printed on a line, with tab-separated creation and last use time.
+`
+	args := c.Parse()
+	if len(args) != 0 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdProvisioningTokenList(xctl())
+}
+
+func ctlcmdProvisioningTokenList(ctl *ctl) {
+	ctl.xwrite("provisioningtokenlist")
+	ctl.xreadok()
+	ctl.xstreamto(os.Stdout)
+}
+
+func cmdProvisioningTokenAdd(c *cmd) {
+	c.params = "name"
+	c.help = `Add a token for the provisioning API, and print it.
+
+The provisioning API must be enabled in mox.conf. Requests must have the token
+in an Authorization header: "Bearer <token>". Only a hash of the token is
+stored, it cannot be retrieved later.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdProvisioningTokenAdd(xctl(), args[0])
+}
+
+func ctlcmdProvisioningTokenAdd(ctl *ctl, name string) {
+	ctl.xwrite("provisioningtokenadd")
+	ctl.xwrite(name)
+	ctl.xreadok()
+	fmt.Println(ctl.xread())
+}
+
+func cmdProvisioningTokenRemove(c *cmd) {
+	c.params = "name"
+	c.help = `Remove a token for the provisioning API.`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdProvisioningTokenRemove(xctl(), args[0])
+}
+
+func ctlcmdProvisioningTokenRemove(ctl *ctl, name string) {
+	ctl.xwrite("provisioningtokenrm")
+	ctl.xwrite(name)
+	ctl.xreadok()
+	fmt.Println("provisioning token removed")
+}
+
 func cmdStop(c *cmd) {
 	c.help = `Shut mox down, giving connections maximum 3 seconds to stop before closing them.
 
@@ -1771,6 +2133,10 @@ func cmdSetadminpassword(c *cmd) {
 
 The password is read from stdin. Its bcrypt hash is stored in a file named
 "adminpasswd" in the configuration directory.
+
+If two-factor authentication is enabled for admin logins, its secret is stored
+in "adminpasswd.totp". Remove that file to disable it, e.g. after losing the
+authenticator app.
 `
 	if len(c.Parse()) != 0 {
 		c.Usage()
@@ -1992,7 +2358,7 @@ sharing most of its code.
 		var permanent bool
 		var origNextHopAuthentic bool
 		var err error
-		haveMX, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, permanent, err = smtpclient.GatherDestinations(ctxbg, c.log.Logger, resolver, dns.IPDomain{Domain: origNextHop})
+		haveMX, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, _, permanent, err = smtpclient.GatherDestinations(ctxbg, c.log.Logger, resolver, dns.IPDomain{Domain: origNextHop})
 		status := "temporary"
 		if permanent {
 			status = "permanent"
@@ -2086,7 +2452,7 @@ sharing most of its code.
 		log.Printf("gathered valid tls certificate names for potential verification with dane-ta: %s", strings.Join(l, ", "))
 
 		dialer := &net.Dialer{Timeout: 5 * time.Second}
-		conn, _, err := smtpclient.Dial(ctxbg, c.log.Logger, dialer, dns.IPDomain{Domain: expandedHost}, ips, 25, dialedIPs, nil)
+		conn, _, err := smtpclient.Dial(ctxbg, c.log.Logger, dialer, dns.IPDomain{Domain: expandedHost}, ips, 25, dialedIPs, nil, smtpclient.DialPolicy{HappyEyeballsDelay: smtpclient.DefaultHappyEyeballsDelay})
 		if err != nil {
 			log.Printf("dial %s: %v, skipping", expandedHost, err)
 			continue
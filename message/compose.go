@@ -26,6 +26,10 @@ type Composer struct {
 	SMTPUTF8 bool  // Whether message needs to be sent with SMTPUTF8 extension.
 	Size     int64 // Total bytes written.
 
+	// If set, TextPart uses quoted-printable for non-ASCII text instead of 8bit. For
+	// content that must not be changed in transit, e.g. with an OpenPGP signature.
+	Require7bit bool
+
 	bw      *bufio.Writer
 	maxSize int64 // If greater than zero, writes beyond maximum size raise ErrMessageSize.
 }
@@ -150,7 +154,7 @@ func (xc *Composer) TextPart(subtype, text string) (textBody []byte, ct, cte str
 	if !isASCII(text) {
 		charset = "utf-8"
 	}
-	if NeedsQuotedPrintable(text) {
+	if NeedsQuotedPrintable(text) || xc.Require7bit && charset == "utf-8" {
 		var sb strings.Builder
 		_, err := io.Copy(quotedprintable.NewWriter(&sb), strings.NewReader(text))
 		xc.Checkf(err, "converting text to quoted printable")
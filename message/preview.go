@@ -243,6 +243,16 @@ var regexpNewline = regexp.MustCompile(`\n\n\n+`)
 var regexpZeroWidth = regexp.MustCompile("[\u00a0\u200b\u200c\u200d][\u00a0\u200b\u200c\u200d]+") // Removed, combinations don't make sense, generated.
 
 func previewHTML(r io.Reader) (string, error) {
+	// We need to generate at most 256 characters of preview. The text we're gathering
+	// will be cleaned up, with quoting removed, so we'll end up with less. Hopefully,
+	// 4k bytes is enough to read.
+	return HTMLText(r, 4*1024)
+}
+
+// HTMLText returns the text of an HTML document, with text of block elements on
+// separate lines, and text in blockquotes prefixed with "> ". Gathering text
+// stops after at least limit bytes of text.
+func HTMLText(r io.Reader, limit int) (string, error) {
 	// Stack/state, based on elements.
 	var ignores []bool
 	var inlines []bool
@@ -325,10 +335,7 @@ func previewHTML(r io.Reader) (string, error) {
 				}
 				text += s
 			}
-			// We need to generate at most 256 characters of preview. The text we're gathering
-			// will be cleaned up, with quoting removed, so we'll end up with less. Hopefully,
-			// 4k bytes is enough to read.
-			if len(text) >= 4*1024 {
+			if len(text) >= limit {
 				return false
 			}
 		}
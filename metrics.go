@@ -11,6 +11,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 
+	"github.com/mjl-/mox/contentrep"
 	"github.com/mjl-/mox/dane"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dmarc"
@@ -20,11 +21,14 @@ import (
 	"github.com/mjl-/mox/metrics"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mtasts"
+	"github.com/mjl-/mox/oidc"
+	"github.com/mjl-/mox/postmasterdb"
 	"github.com/mjl-/mox/smtpclient"
 	"github.com/mjl-/mox/spf"
 	"github.com/mjl-/mox/subjectpass"
 	"github.com/mjl-/mox/tlsrpt"
 	"github.com/mjl-/mox/updates"
+	"github.com/mjl-/mox/wkd"
 )
 
 var metricHTTPClient = promauto.NewHistogramVec(
@@ -80,6 +84,36 @@ func init() {
 			Help: "Total number of DANE verification attempts, including mox_dane_verify_errors_total.",
 		},
 	)
+	contentrep.MetricLookup = histogramVec{promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mox_contentrep_lookup_duration_seconds",
+			Help:    "URI and attachment hash block list lookups, not including cached results.",
+			Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.100, 0.5, 1, 5, 10, 20},
+		},
+		[]string{
+			"zone",
+			"status", // pass, fail, temperror
+		},
+	)}
+	contentrep.MetricCache = counterVec{promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_contentrep_cache_total",
+			Help: "URI and attachment hash block list lookups, by whether the result was cached.",
+		},
+		[]string{
+			"result", // hit, miss
+		},
+	)}
+	contentrep.MetricListed = counterVec{promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_contentrep_listed_total",
+			Help: "URLs and attachments of incoming messages found in a reputation source.",
+		},
+		[]string{
+			"kind",   // url, attachment
+			"source", // local, dns
+		},
+	)}
 	dane.MetricVerifyErrors = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "mox_dane_verify_errors_total",
@@ -148,6 +182,15 @@ func init() {
 			"status",
 		},
 	)}
+	dnsbl.MetricCache = counterVec{promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_dnsbl_cache_total",
+			Help: "DNSBL lookups, by whether the result was cached.",
+		},
+		[]string{
+			"result", // hit, miss
+		},
+	)}
 
 	iprev.MetricIPRev = histogramVec{promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -169,6 +212,9 @@ func init() {
 		},
 	)}
 	mtasts.HTTPClientObserve = httpClientObserve
+	oidc.HTTPClientObserve = httpClientObserve
+	postmasterdb.HTTPClientObserve = httpClientObserve
+	wkd.HTTPClientObserve = httpClientObserve
 
 	smtpclient.MetricCommands = histogramVec{promauto.NewHistogramVec(
 		prometheus.HistogramOpts{
@@ -26,6 +26,8 @@ const (
 	Imapserver       Panic = "imapserver"
 	Dmarcdb          Panic = "dmarcdb"
 	Mtastsdb         Panic = "mtastsdb"
+	Postmasterdb     Panic = "postmasterdb"
+	Mlist            Panic = "mlist"
 	Queue            Panic = "queue"
 	Smtpclient       Panic = "smtpclient"
 	Smtpserver       Panic = "smtpserver"
@@ -38,11 +40,15 @@ const (
 	Store            Panic = "store"
 	Webadmin         Panic = "webadmin"
 	Webapi           Panic = "webapi"
+	Webops           Panic = "webops"
 	Webmailsendevent Panic = "webmailsendevent"
 	Webmail          Panic = "webmail"
 	Webmailrequest   Panic = "webmailrequest"
 	Webmailquery     Panic = "webmailquery"
 	Webmailhandle    Panic = "webmailhandle"
+	Webpush          Panic = "webpush"
+	Provisioning     Panic = "provisioning"
+	Dnsprovision     Panic = "dnsprovision"
 )
 
 func init() {
@@ -54,6 +60,8 @@ func init() {
 		Serve,
 		Imapserver,
 		Mtastsdb,
+		Postmasterdb,
+		Mlist,
 		Queue,
 		Smtpclient,
 		Smtpserver,
@@ -63,11 +71,15 @@ func init() {
 		Importmanage,
 		Importmessages,
 		Webadmin,
+		Webops,
 		Webmailsendevent,
 		Webmail,
 		Webmailrequest,
 		Webmailquery,
 		Webmailhandle,
+		Webpush,
+		Provisioning,
+		Dnsprovision,
 	}
 	for _, name := range names {
 		metricPanic.WithLabelValues(string(name)).Add(0)
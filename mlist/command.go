@@ -0,0 +1,78 @@
+package mlist
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dsn"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/smtp"
+)
+
+var ErrUnknownCommand = errors.New("unknown list command")
+
+// Command executes an email command for a list, as parsed with ParseCommand from
+// the recipient address of an incoming message. The message is only used for
+// delivery failure notifications to bounce addresses.
+//
+// Commands other than bounces require a non-null SMTP MAIL FROM, so we don't
+// respond to delivery failure notifications. Subscribe and unsubscribe requests
+// are for the address in the message From header.
+func Command(ctx context.Context, log mlog.Log, alias config.Alias, command string, mailFrom smtp.Path, msgFrom smtp.Address, msg io.ReaderAt) error {
+	listAddr, err := ListAddress(alias)
+	if err != nil {
+		return err
+	}
+	list := listAddr.String()
+	log = log.With(slog.String("list", list), slog.String("command", command))
+
+	if token, ok := strings.CutPrefix(command, "bounce-"); ok {
+		dsnmsg, _, err := dsn.Parse(log.Logger, msg)
+		if err != nil {
+			log.Infox("parsing delivery failure notification for list, ignoring", err)
+			return nil
+		}
+		for _, r := range dsnmsg.Recipients {
+			if r.Action == dsn.Failed {
+				return bounce(ctx, log, token)
+			}
+		}
+		log.Info("delivery status notification for list without failures, ignoring")
+		return nil
+	}
+
+	if mailFrom.IsZero() {
+		log.Info("ignoring list command from null reverse path")
+		return nil
+	}
+
+	// tokenCommand executes fn for a command with a token, if the token belongs to
+	// this list.
+	tokenCommand := func(token string, fn func(ctx context.Context, log mlog.Log, token string) (Subscriber, error)) error {
+		s, err := SubscriptionGet(ctx, token)
+		if err != nil {
+			return err
+		} else if s.List != list {
+			return ErrUnknownToken
+		}
+		_, err = fn(ctx, log, token)
+		return err
+	}
+
+	switch {
+	case command == "subscribe":
+		return subscribe(ctx, log, alias, msgFrom)
+	case command == "unsubscribe":
+		return unsubscribeRequest(ctx, log, alias, msgFrom)
+	case strings.HasPrefix(command, "confirm-"):
+		return tokenCommand(strings.TrimPrefix(command, "confirm-"), Confirm)
+	case strings.HasPrefix(command, "unsubscribe-"):
+		return tokenCommand(strings.TrimPrefix(command, "unsubscribe-"), Unsubscribe)
+	}
+	return fmt.Errorf("%w: %q", ErrUnknownCommand, command)
+}
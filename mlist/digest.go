@@ -0,0 +1,237 @@
+package mlist
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"runtime/debug"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// Interval between digests of a list.
+const digestInterval = 24 * time.Hour
+
+// Start launches a goroutine that sends digests to subscribers that opted for
+// them, checking each hour for lists whose last digest is at least a day ago.
+func Start() {
+	go func() {
+		log := mlog.New("mlist", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recover from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Mlist)
+			}
+		}()
+
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-mox.Shutdown.Done():
+				log.Info("list digest sender shutting down")
+				return
+			case <-timer.C:
+			}
+
+			clog := log.WithCid(mox.Cid())
+			err := sendDigests(mox.Shutdown, clog, time.Now())
+			clog.Check(err, "sending list digests")
+			timer.Reset(time.Hour)
+		}
+	}()
+}
+
+// sendDigests sends digests for all lists whose interval has passed.
+func sendDigests(ctx context.Context, log mlog.Log, now time.Time) error {
+	for _, name := range mox.Conf.Domains() {
+		dc, ok := mox.Conf.Domain(dns.Domain{ASCII: name})
+		if !ok {
+			continue
+		}
+		for _, a := range dc.Aliases {
+			if a.List == nil {
+				continue
+			}
+			if err := sendDigest(ctx, log, a, now); err != nil {
+				log.Errorx("sending digest for list", err, slog.String("list", a.LocalpartStr+"@"+name))
+			}
+		}
+	}
+	return nil
+}
+
+// sendDigest sends a digest with the pending messages of a list to its digest
+// subscribers, if the last digest was sent at least a day ago.
+func sendDigest(ctx context.Context, log mlog.Log, alias config.Alias, now time.Time) error {
+	listAddr, err := ListAddress(alias)
+	if err != nil {
+		return err
+	}
+	list := listAddr.String()
+
+	d := Digest{List: list}
+	err = DB.Get(ctx, &d)
+	exists := err == nil
+	if err != nil && err != bstore.ErrAbsent {
+		return fmt.Errorf("get last digest: %v", err)
+	} else if now.Sub(d.LastSent) < digestInterval {
+		return nil
+	}
+
+	q := bstore.QueryDB[Message](ctx, DB)
+	q.FilterNonzero(Message{List: list})
+	q.FilterEqual("DigestPending", true)
+	q.SortAsc("Received")
+	msgs, err := q.List()
+	if err != nil {
+		return fmt.Errorf("listing pending messages: %v", err)
+	}
+	if len(msgs) == 0 {
+		return nil
+	}
+
+	q2 := bstore.QueryDB[Subscriber](ctx, DB)
+	q2.FilterNonzero(Subscriber{List: list})
+	q2.FilterEqual("Confirmed", true)
+	q2.FilterEqual("Digest", true)
+	subs, err := q2.List()
+	if err != nil {
+		return fmt.Errorf("listing digest subscribers: %v", err)
+	}
+
+	if len(subs) > 0 {
+		if err := queueDigest(ctx, log, alias, listAddr, msgs, subs); err != nil {
+			return err
+		}
+	}
+
+	// Mark messages as sent in a digest, removing those not archived.
+	return DB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, m := range msgs {
+			var err error
+			if m.Archive {
+				m.DigestPending = false
+				err = tx.Update(&m)
+			} else {
+				err = tx.Delete(&m)
+			}
+			if err != nil {
+				return fmt.Errorf("updating message after digest: %v", err)
+			}
+		}
+		d.LastSent = now
+		if exists {
+			return tx.Update(&d)
+		}
+		return tx.Insert(&d)
+	})
+}
+
+// queueDigest composes a digest of msgs and queues it for the subscribers.
+func queueDigest(ctx context.Context, log mlog.Log, alias config.Alias, listAddr smtp.Address, msgs []Message, subs []Subscriber) error {
+	f, err := store.CreateMessageTemp(log, "mlist-digest")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "list digest")
+
+	subject := fmt.Sprintf("Digest for %s, %d messages", listAddr, len(msgs))
+	messageID, err := composeDigest(f, alias, listAddr, subject, msgs)
+	if err != nil {
+		return err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("stat digest: %v", err)
+	}
+
+	msgPrefix := dkimSign(ctx, log, listAddr, false, f)
+	var has8bit bool
+	for _, m := range msgs {
+		has8bit = has8bit || bytes.ContainsFunc(m.Data, func(r rune) bool { return r >= 0x80 })
+	}
+	size := int64(len(msgPrefix)) + fi.Size()
+
+	qml := make([]queue.Msg, len(subs))
+	for i, s := range subs {
+		rcpt, err := smtp.ParseAddress(s.Address)
+		if err != nil {
+			return fmt.Errorf("parsing subscriber address: %v", err)
+		}
+		sender := commandAddress(alias, "bounce-"+s.Token)
+		qm := queue.MakeMsg(sender.Path(), rcpt.Path(), has8bit, false, size, messageID, []byte(msgPrefix), nil, time.Now(), subject)
+		qm.IsListMessage = true
+		qml[i] = qm
+	}
+	if err := queueAdd(ctx, log, mox.Conf.Static.Postmaster.Account, f, qml...); err != nil {
+		return fmt.Errorf("queueing digest: %v", err)
+	}
+	log.Info("list digest queued", slog.String("list", listAddr.String()), slog.Int("messages", len(msgs)), slog.Int("subscribers", len(qml)))
+	return nil
+}
+
+// composeDigest writes a multipart/digest message with the messages as parts.
+func composeDigest(f *os.File, alias config.Alias, listAddr smtp.Address, subject string, msgs []Message) (messageID string, rerr error) {
+	xc := message.NewComposer(f, 0, false)
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(error); ok && errors.Is(err, message.ErrCompose) {
+			rerr = err
+			return
+		}
+		panic(x)
+	}()
+
+	xc.HeaderAddrs("From", []message.NameAddress{{Address: listAddr}})
+	xc.HeaderAddrs("To", []message.NameAddress{{Address: listAddr}})
+	xc.Subject(subject)
+	messageID = fmt.Sprintf("<%s>", mox.MessageIDGen(false))
+	xc.Header("Message-Id", messageID)
+	xc.Header("Date", time.Now().Format(message.RFC5322Z))
+	xc.Header("User-Agent", "mox/"+moxvar.Version)
+	_, err := xc.Write([]byte(listHeaders(alias, listAddr, false)))
+	xc.Checkf(err, "writing list headers")
+	xc.Header("MIME-Version", "1.0")
+
+	mp := multipart.NewWriter(xc)
+	xc.Header("Content-Type", fmt.Sprintf(`multipart/digest; boundary="%s"`, mp.Boundary()))
+	xc.Line()
+	// Parts in a multipart/digest are message/rfc822 by default.
+	for _, m := range msgs {
+		pw, err := mp.CreatePart(textproto.MIMEHeader{})
+		xc.Checkf(err, "adding message to digest")
+		_, err = pw.Write(m.Data)
+		xc.Checkf(err, "writing message to digest")
+	}
+	err = mp.Close()
+	xc.Checkf(err, "finishing digest")
+
+	xc.Flush()
+	return messageID, nil
+}
@@ -0,0 +1,438 @@
+/*
+Package mlist implements mailing lists on top of aliases.
+
+An alias with a List configuration in domains.conf is a mailing list. Besides
+its member addresses of local accounts, a list has subscribers, stored in the
+mlist database, which can be external addresses. Messages accepted for the list
+are delivered to the members as for regular aliases, and sent to the confirmed
+subscribers through the queue. Subscribers can opt for a daily digest instead.
+
+Email commands are sent to the list address with the command after a localpart
+catchall separator, e.g. list+subscribe@example.com:
+
+  - subscribe: Subscribe the address in the message From header, if the list
+    allows subscribing. A confirmation message is sent to the address, which
+    has to be answered or followed before the subscription is active (double
+    opt-in).
+  - unsubscribe: Send a message to the subscribed address in the message From
+    header, to confirm unsubscribing.
+  - confirm-<token>: Confirm a subscription.
+  - unsubscribe-<token>: Confirm unsubscribing.
+  - bounce-<token>: Used as SMTP MAIL FROM address for messages to a subscriber,
+    for matching delivery failure notifications to the subscriber. After
+    too many failures, subscribers are unsubscribed automatically.
+
+The same can be done on the web page at list/ below the account web interface.
+*/
+package mlist
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+)
+
+var pkglog = mlog.New("mlist", nil)
+
+var (
+	DBTypes = []any{Subscriber{}, Message{}, Digest{}}
+	DB      *bstore.DB
+)
+
+// Time after which earlier delivery failures of a subscriber are forgotten.
+const bounceForget = 30 * 24 * time.Hour
+
+// Default number of delivery failures after which a subscriber is removed.
+const bounceLimitDefault = 5
+
+var (
+	ErrUnknownList  = errors.New("unknown list")
+	ErrUnknownToken = errors.New("unknown token")
+	ErrClosed       = errors.New("list does not allow subscribing")
+)
+
+// Subscriber is an address subscribed to a list.
+type Subscriber struct {
+	ID         int64
+	List       string    `bstore:"nonzero,unique List+Address"` // Canonical list address, e.g. list@example.com.
+	Address    string    `bstore:"nonzero"`                     // Subscribed address.
+	Token      string    `bstore:"nonzero,unique"`              // For confirming and managing the subscription, and matching delivery failures.
+	Confirmed  bool      // Only confirmed subscribers get messages.
+	Digest     bool      // Receive a daily digest instead of each message.
+	Bounces    int       // Number of delivery failures, reset after 30 days without failures.
+	LastBounce time.Time // Time of last delivery failure.
+	Created    time.Time `bstore:"default now"`
+}
+
+// Message is a message sent to a list, kept in the archive and/or for the next
+// digest.
+type Message struct {
+	ID            int64
+	List          string    `bstore:"nonzero,index List+Received"`
+	Received      time.Time `bstore:"default now"`
+	MessageID     string
+	From          string // Address from message From header, before any rewriting.
+	Subject       string
+	Size          int64
+	Archive       bool   // Whether to keep the message after including it in a digest.
+	DigestPending bool   // Whether the message still has to be included in a digest.
+	Data          []byte `json:"-"` // Message as received, without our headers.
+}
+
+// Digest holds the time the last digest was sent for a list.
+type Digest struct {
+	List     string // Canonical list address.
+	LastSent time.Time
+}
+
+// Init opens the database.
+func Init() error {
+	if DB != nil {
+		return fmt.Errorf("already initialized")
+	}
+
+	log := mlog.New("mlist", nil)
+	p := mox.DataDirPath("mlist.db")
+	os.MkdirAll(filepath.Dir(p), 0770)
+	opts := bstore.Options{Timeout: 5 * time.Second, Perm: 0660, RegisterLogger: moxvar.RegisterLogger(p, log.Logger)}
+	var err error
+	DB, err = bstore.Open(mox.Shutdown, p, &opts, DBTypes...)
+	if err != nil {
+		return err
+	}
+	queue.ListBounce = queueBounce
+	return nil
+}
+
+// Close closes the database.
+func Close() error {
+	if err := DB.Close(); err != nil {
+		return fmt.Errorf("closing db: %w", err)
+	}
+	DB = nil
+	return nil
+}
+
+func newToken() string {
+	buf := make([]byte, 12)
+	cryptorand.Read(buf)
+	// Lower case, tokens are used in localparts, which may be lower-cased.
+	return hex.EncodeToString(buf)
+}
+
+// ListAddress returns the canonical address of the list for an alias.
+func ListAddress(alias config.Alias) (smtp.Address, error) {
+	lp, err := smtp.ParseLocalpart(alias.LocalpartStr)
+	if err != nil {
+		return smtp.Address{}, fmt.Errorf("parsing alias localpart: %v", err)
+	}
+	return smtp.NewAddress(lp, alias.Domain), nil
+}
+
+// lookupList returns the alias configuration for a list address.
+func lookupList(list string) (config.Alias, error) {
+	_, alias, ok := mox.Conf.AccountDestination(list)
+	if !ok || alias == nil || alias.List == nil {
+		return config.Alias{}, ErrUnknownList
+	}
+	return *alias, nil
+}
+
+// commandAddress returns the address for a command to the list, e.g.
+// list+subscribe@example.com.
+func commandAddress(alias config.Alias, command string) smtp.Address {
+	d, _ := mox.Conf.Domain(alias.Domain)
+	sep := "+"
+	if len(d.LocalpartCatchallSeparatorsEffective) > 0 {
+		sep = d.LocalpartCatchallSeparatorsEffective[0]
+	}
+	return smtp.NewAddress(smtp.Localpart(alias.LocalpartStr+sep+command), alias.Domain)
+}
+
+// ParseCommand returns the email command in the localpart of an address for the
+// alias of a list, e.g. "subscribe" for "list+subscribe". An empty string is
+// returned if the localpart has no command.
+func ParseCommand(d config.Domain, alias config.Alias, localpart smtp.Localpart) string {
+	lp := string(localpart)
+	if !d.LocalpartCaseSensitive {
+		lp = strings.ToLower(lp)
+	}
+	for _, sep := range d.LocalpartCatchallSeparatorsEffective {
+		if cmd, ok := strings.CutPrefix(lp, strings.ToLower(alias.LocalpartStr)+sep); ok {
+			return cmd
+		}
+	}
+	return ""
+}
+
+// Subscribers returns the subscribers of a list, confirmed or not.
+func Subscribers(ctx context.Context, list string) ([]Subscriber, error) {
+	q := bstore.QueryDB[Subscriber](ctx, DB)
+	q.FilterNonzero(Subscriber{List: list})
+	q.SortAsc("Address")
+	return q.List()
+}
+
+// IsSubscriber returns whether address is a confirmed subscriber of the list.
+func IsSubscriber(ctx context.Context, list string, address smtp.Address) (bool, error) {
+	q := bstore.QueryDB[Subscriber](ctx, DB)
+	q.FilterNonzero(Subscriber{List: list, Address: address.String()})
+	q.FilterEqual("Confirmed", true)
+	return q.Exists()
+}
+
+// SubscriberAdd adds a confirmed subscriber to a list, as done by an admin.
+func SubscriberAdd(ctx context.Context, list string, address smtp.Address, digest bool) (Subscriber, error) {
+	if _, err := lookupList(list); err != nil {
+		return Subscriber{}, err
+	}
+	s := Subscriber{List: list, Address: address.String(), Token: newToken(), Confirmed: true, Digest: digest}
+	if err := DB.Insert(ctx, &s); err != nil {
+		if errors.Is(err, bstore.ErrUnique) {
+			return Subscriber{}, fmt.Errorf("address already subscribed")
+		}
+		return Subscriber{}, fmt.Errorf("adding subscriber: %v", err)
+	}
+	return s, nil
+}
+
+// SubscriberRemove removes a subscriber from a list.
+func SubscriberRemove(ctx context.Context, list string, id int64) error {
+	n, err := bstore.QueryDB[Subscriber](ctx, DB).FilterNonzero(Subscriber{ID: id, List: list}).Delete()
+	if err != nil {
+		return fmt.Errorf("removing subscriber: %v", err)
+	} else if n == 0 {
+		return fmt.Errorf("no such subscriber")
+	}
+	return nil
+}
+
+// SubscriptionGet returns the subscription for a token.
+func SubscriptionGet(ctx context.Context, token string) (Subscriber, error) {
+	s, err := bstore.QueryDB[Subscriber](ctx, DB).FilterNonzero(Subscriber{Token: token}).Get()
+	if err == bstore.ErrAbsent {
+		return Subscriber{}, ErrUnknownToken
+	}
+	return s, err
+}
+
+// subscribe adds an unconfirmed subscription and sends the confirmation message.
+// If the address is already subscribed, the confirmation message is sent again for
+// an unconfirmed subscription.
+func subscribe(ctx context.Context, log mlog.Log, alias config.Alias, address smtp.Address) error {
+	if !alias.List.Subscribe {
+		return ErrClosed
+	}
+	listAddr, err := ListAddress(alias)
+	if err != nil {
+		return err
+	}
+	list := listAddr.String()
+
+	var s Subscriber
+	err = DB.Write(ctx, func(tx *bstore.Tx) error {
+		var err error
+		s, err = bstore.QueryTx[Subscriber](tx).FilterNonzero(Subscriber{List: list, Address: address.String()}).Get()
+		if err == nil || err != bstore.ErrAbsent {
+			return err
+		}
+		s = Subscriber{List: list, Address: address.String(), Token: newToken()}
+		return tx.Insert(&s)
+	})
+	if err != nil {
+		return fmt.Errorf("adding subscriber: %v", err)
+	}
+	if s.Confirmed {
+		log.Info("subscribe for address that is already subscribed", slog.String("list", list), slog.String("address", s.Address))
+		return nil
+	}
+	log.Info("subscription requested", slog.String("list", list), slog.String("address", s.Address))
+
+	text := fmt.Sprintf(`Hi,
+
+Someone, hopefully you, requested to subscribe this address to the mailing
+list %s.
+
+To confirm, reply to this message, or open:
+
+%s
+
+If you did not request this subscription, you can ignore this message.
+`, list, subscriptionURL(s.Token))
+	return sendMessage(ctx, log, alias, "confirm-"+s.Token, address, "Confirm subscription to "+list, text)
+}
+
+// unsubscribeRequest sends a message to confirm unsubscribing an address.
+// Requests for addresses that aren't subscribed are ignored.
+func unsubscribeRequest(ctx context.Context, log mlog.Log, alias config.Alias, address smtp.Address) error {
+	listAddr, err := ListAddress(alias)
+	if err != nil {
+		return err
+	}
+	list := listAddr.String()
+
+	s, err := bstore.QueryDB[Subscriber](ctx, DB).FilterNonzero(Subscriber{List: list, Address: address.String()}).Get()
+	if err == bstore.ErrAbsent {
+		log.Info("unsubscribe for address that is not subscribed", slog.String("list", list), slog.String("address", address.String()))
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("looking up subscriber: %v", err)
+	}
+
+	text := fmt.Sprintf(`Hi,
+
+Someone, hopefully you, requested to unsubscribe this address from the mailing
+list %s.
+
+To confirm, reply to this message, or open:
+
+%s
+
+If you did not request this, you can ignore this message.
+`, list, subscriptionURL(s.Token))
+	return sendMessage(ctx, log, alias, "unsubscribe-"+s.Token, address, "Confirm unsubscribing from "+list, text)
+}
+
+// Confirm confirms a subscription by its token.
+func Confirm(ctx context.Context, log mlog.Log, token string) (Subscriber, error) {
+	var s Subscriber
+	err := DB.Write(ctx, func(tx *bstore.Tx) error {
+		var err error
+		s, err = bstore.QueryTx[Subscriber](tx).FilterNonzero(Subscriber{Token: token}).Get()
+		if err == bstore.ErrAbsent {
+			return ErrUnknownToken
+		} else if err != nil {
+			return err
+		}
+		s.Confirmed = true
+		return tx.Update(&s)
+	})
+	if err == nil {
+		log.Info("subscription confirmed", slog.String("list", s.List), slog.String("address", s.Address))
+	}
+	return s, err
+}
+
+// Unsubscribe removes a subscription by its token.
+func Unsubscribe(ctx context.Context, log mlog.Log, token string) (Subscriber, error) {
+	var s Subscriber
+	err := DB.Write(ctx, func(tx *bstore.Tx) error {
+		var err error
+		s, err = bstore.QueryTx[Subscriber](tx).FilterNonzero(Subscriber{Token: token}).Get()
+		if err == bstore.ErrAbsent {
+			return ErrUnknownToken
+		} else if err != nil {
+			return err
+		}
+		return tx.Delete(&s)
+	})
+	if err == nil {
+		log.Info("unsubscribed", slog.String("list", s.List), slog.String("address", s.Address))
+	}
+	return s, err
+}
+
+// DigestSave sets whether a subscriber receives digests.
+func DigestSave(ctx context.Context, token string, digest bool) error {
+	return DB.Write(ctx, func(tx *bstore.Tx) error {
+		s, err := bstore.QueryTx[Subscriber](tx).FilterNonzero(Subscriber{Token: token}).Get()
+		if err == bstore.ErrAbsent {
+			return ErrUnknownToken
+		} else if err != nil {
+			return err
+		}
+		s.Digest = digest
+		return tx.Update(&s)
+	})
+}
+
+// bounce registers a delivery failure for the subscriber with token. When the
+// bounce limit of the list is reached, the subscriber is removed.
+func bounce(ctx context.Context, log mlog.Log, token string) error {
+	return DB.Write(ctx, func(tx *bstore.Tx) error {
+		s, err := bstore.QueryTx[Subscriber](tx).FilterNonzero(Subscriber{Token: token}).Get()
+		if err == bstore.ErrAbsent {
+			log.Info("delivery failure for unknown subscriber, ignoring")
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		limit := bounceLimitDefault
+		if alias, err := lookupList(s.List); err == nil && alias.List.BounceLimit > 0 {
+			limit = alias.List.BounceLimit
+		}
+
+		now := time.Now()
+		if now.Sub(s.LastBounce) > bounceForget {
+			s.Bounces = 0
+		}
+		s.Bounces++
+		s.LastBounce = now
+		if s.Bounces >= limit {
+			log.Info("unsubscribing after too many delivery failures", slog.String("list", s.List), slog.String("address", s.Address), slog.Int("bounces", s.Bounces))
+			return tx.Delete(&s)
+		}
+		log.Info("delivery failure for subscriber", slog.String("list", s.List), slog.String("address", s.Address), slog.Int("bounces", s.Bounces))
+		return tx.Update(&s)
+	})
+}
+
+// queueBounce is called by the queue for permanent delivery failures of list
+// messages, with the bounce address as sender.
+func queueBounce(log mlog.Log, sender, recipient smtp.Path) {
+	_, alias, _, _, err := mox.LookupAddress(sender.Localpart, sender.IPDomain.Domain, false, true, false)
+	if err != nil || alias == nil || alias.List == nil {
+		log.Infox("delivery failure for message from unknown list", err, slog.Any("sender", sender))
+		return
+	}
+	d, _ := mox.Conf.Domain(alias.Domain)
+	token, ok := strings.CutPrefix(ParseCommand(d, *alias, sender.Localpart), "bounce-")
+	if !ok {
+		log.Info("delivery failure for list message without bounce address", slog.Any("sender", sender))
+		return
+	}
+	err = bounce(context.Background(), log, token)
+	log.Check(err, "processing delivery failure for list subscriber", slog.Any("recipient", recipient))
+}
+
+// Archive returns the archived messages for a list, most recent first, without
+// message data.
+func Archive(ctx context.Context, list string) ([]Message, error) {
+	q := bstore.QueryDB[Message](ctx, DB)
+	q.FilterNonzero(Message{List: list})
+	q.FilterEqual("Archive", true)
+	q.SortDesc("Received")
+	var l []Message
+	err := q.ForEach(func(m Message) error {
+		m.Data = nil
+		l = append(l, m)
+		return nil
+	})
+	return l, err
+}
+
+// ArchiveMessage returns an archived message of a list, including data.
+func ArchiveMessage(ctx context.Context, list string, id int64) (Message, error) {
+	m, err := bstore.QueryDB[Message](ctx, DB).FilterNonzero(Message{ID: id, List: list}).FilterEqual("Archive", true).Get()
+	if err == bstore.ErrAbsent {
+		return Message{}, fmt.Errorf("no such message")
+	}
+	return m, err
+}
@@ -0,0 +1,307 @@
+package mlist
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+const domainsConf = `Domains:
+	mox.example:
+		LocalpartCatchallSeparator: +
+		Aliases:
+			list:
+				Addresses:
+					- mjl@mox.example
+				PostPolicy: anyone
+				List:
+					Subscribe: true
+					Archive: true
+					BounceLimit: 2
+Accounts:
+	mjl:
+		Domain: mox.example
+		Destinations:
+			mjl@mox.example: nil
+`
+
+func TestList(t *testing.T) {
+	dir := t.TempDir()
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	buf, err := os.ReadFile(filepath.FromSlash("../testdata/store/mox.conf"))
+	tcheck(t, err, "read config")
+	err = os.WriteFile(mox.ConfigStaticPath, buf, 0660)
+	tcheck(t, err, "write config")
+	err = os.WriteFile(mox.ConfigDynamicPath, []byte(domainsConf), 0660)
+	tcheck(t, err, "write config")
+	mox.MustLoadConfig(true, false)
+	mox.LimitersInit()
+	err = Init()
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+
+	// Capture the queued messages.
+	type queued struct {
+		sender, rcpt, text string
+		isList             bool
+	}
+	var messages []queued
+	queueAdd = func(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.File, qml ...queue.Msg) error {
+		buf, err := io.ReadAll(&moxio.AtReader{R: msgFile})
+		tcheck(t, err, "read message")
+		for _, qm := range qml {
+			messages = append(messages, queued{qm.Sender().String(), qm.Recipient().String(), string(qm.MsgPrefix) + string(buf), qm.IsListMessage})
+		}
+		return nil
+	}
+	defer func() {
+		queueAdd = queue.Add
+	}()
+
+	log := pkglog
+	const list = "list@mox.example"
+	_, alias, ok := mox.Conf.AccountDestination(list)
+	if !ok || alias == nil || alias.List == nil {
+		t.Fatalf("list not found")
+	}
+	d, _ := mox.Conf.Domain(dns.Domain{ASCII: "mox.example"})
+
+	if cmd := ParseCommand(d, *alias, "List+Subscribe"); cmd != "subscribe" {
+		t.Fatalf("parse command, got %q", cmd)
+	}
+	if cmd := ParseCommand(d, *alias, "list"); cmd != "" {
+		t.Fatalf("parse command without command, got %q", cmd)
+	}
+
+	remote := smtp.NewAddress("remote", dns.Domain{ASCII: "remote.example"})
+	mailFrom := remote.Path()
+
+	tokenRegexp := regexp.MustCompile(`list\+confirm-([0-9a-f]+)@mox.example`)
+	xtoken := func() string {
+		t.Helper()
+		if len(messages) != 1 {
+			t.Fatalf("got %d messages, expected 1", len(messages))
+		}
+		m := tokenRegexp.FindStringSubmatch(messages[0].text)
+		if m == nil {
+			t.Fatalf("no confirmation address in message %q", messages[0].text)
+		}
+		messages = nil
+		return m[1]
+	}
+
+	// Subscribe by email, with confirmation message.
+	err = Command(ctxbg, log, *alias, "subscribe", mailFrom, remote, nil)
+	tcheck(t, err, "subscribe")
+	token := xtoken()
+	if ok, err := IsSubscriber(ctxbg, list, remote); err != nil || ok {
+		t.Fatalf("unconfirmed address is subscriber, ok %v, err %v", ok, err)
+	}
+
+	// Commands from the null reverse path are ignored.
+	err = Command(ctxbg, log, *alias, "confirm-"+token, smtp.Path{}, remote, nil)
+	tcheck(t, err, "confirm from null reverse path")
+	if ok, _ := IsSubscriber(ctxbg, list, remote); ok {
+		t.Fatalf("confirmed from null reverse path")
+	}
+
+	err = Command(ctxbg, log, *alias, "confirm-bogus", mailFrom, remote, nil)
+	if err != ErrUnknownToken {
+		t.Fatalf("confirm with bogus token, got %v, expected ErrUnknownToken", err)
+	}
+	err = Command(ctxbg, log, *alias, "bogus", mailFrom, remote, nil)
+	if err == nil {
+		t.Fatalf("unknown command did not fail")
+	}
+
+	err = Command(ctxbg, log, *alias, "confirm-"+token, mailFrom, remote, nil)
+	tcheck(t, err, "confirm")
+	if ok, err := IsSubscriber(ctxbg, list, remote); err != nil || !ok {
+		t.Fatalf("confirmed address not subscriber, ok %v, err %v", ok, err)
+	}
+
+	// Admin adds a subscriber.
+	other := smtp.NewAddress("other", dns.Domain{ASCII: "other.example"})
+	_, err = SubscriberAdd(ctxbg, list, other, false)
+	tcheck(t, err, "add subscriber")
+	_, err = SubscriberAdd(ctxbg, list, other, false)
+	if err == nil {
+		t.Fatalf("adding subscriber twice did not fail")
+	}
+	subs, err := Subscribers(ctxbg, list)
+	tcheck(t, err, "subscribers")
+	if len(subs) != 2 {
+		t.Fatalf("got %d subscribers, expected 2", len(subs))
+	}
+
+	// Distribute a message from remote, which does not get it back. The From address
+	// is rewritten.
+	msg := strings.ReplaceAll(`From: Remote <remote@remote.example>
+To: list@mox.example
+Subject: hi
+Message-Id: <test@remote.example>
+
+hello
+`, "\n", "\r\n")
+	distribute := func(msg string, rewriteFrom bool) {
+		t.Helper()
+		f, err := os.CreateTemp(dir, "msg")
+		tcheck(t, err, "create temp")
+		defer f.Close()
+		_, err = f.WriteString(msg)
+		tcheck(t, err, "write message")
+		authRes := message.AuthResults{Hostname: "mox.example"}
+		err = Distribute(ctxbg, log, dns.MockResolver{}, *alias, authRes, rewriteFrom, false, remote, f)
+		tcheck(t, err, "distribute")
+	}
+	distribute(msg, true)
+	if len(messages) != 1 || messages[0].rcpt != other.String() || !messages[0].isList || !strings.HasPrefix(messages[0].sender, "list+bounce-") {
+		t.Fatalf("unexpected distributed messages %#v", messages)
+	}
+	text := messages[0].text
+	for _, s := range []string{"List-Id: <list.mox.example>\r\n", "List-Unsubscribe: <mailto:list+unsubscribe@mox.example>", `From: "Remote via list@mox.example" <list@mox.example>`, "Reply-To: <remote@remote.example>\r\n", "\r\n\r\nhello\r\n"} {
+		if !strings.Contains(text, s) {
+			t.Fatalf("distributed message does not contain %q: %q", s, text)
+		}
+	}
+	messages = nil
+
+	// Loops are prevented.
+	distribute(text, false)
+	if len(messages) != 0 {
+		t.Fatalf("message with list-id was distributed again")
+	}
+
+	// Archive.
+	archive, err := Archive(ctxbg, list)
+	tcheck(t, err, "archive")
+	if len(archive) != 1 || archive[0].Subject != "hi" || archive[0].Data != nil {
+		t.Fatalf("unexpected archive %#v", archive)
+	}
+	am, err := ArchiveMessage(ctxbg, list, archive[0].ID)
+	tcheck(t, err, "archive message")
+	if string(am.Data) != msg {
+		t.Fatalf("archived message differs")
+	}
+
+	// Web page for the subscription, switching to digest.
+	handler := Handler(false)
+	do := func(method, path string, form url.Values, expStatus int, expText string) {
+		t.Helper()
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req := httptest.NewRequest(method, path, body)
+		req.RemoteAddr = "127.0.0.1:1234"
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != expStatus {
+			t.Fatalf("%s %s: got status %d, expected %d: %s", method, path, rec.Code, expStatus, rec.Body.String())
+		}
+		if !strings.Contains(rec.Body.String(), expText) {
+			t.Fatalf("%s %s: response does not contain %q: %s", method, path, expText, rec.Body.String())
+		}
+	}
+	do("GET", "/", nil, http.StatusOK, list)
+	do("GET", "/subscription?token="+token, nil, http.StatusOK, "Receive daily digest")
+	do("POST", "/subscription", url.Values{"token": {token}, "action": {"digest"}}, http.StatusOK, "daily digest")
+	do("POST", "/subscription", url.Values{"token": {"bogus"}, "action": {"digest"}}, http.StatusBadRequest, "unknown token")
+
+	// Subscribe through web page, confirmation message is sent.
+	third := smtp.NewAddress("third", dns.Domain{ASCII: "other.example"})
+	do("POST", "/", url.Values{"list": {list}, "address": {third.String()}, "action": {"subscribe"}}, http.StatusOK, "confirmation link")
+	thirdToken := xtoken()
+	do("POST", "/subscription", url.Values{"token": {thirdToken}, "action": {"confirm"}}, http.StatusOK, "confirmed")
+	do("POST", "/", url.Values{"list": {"bogus@mox.example"}, "address": {third.String()}, "action": {"subscribe"}}, http.StatusBadRequest, "unknown list")
+
+	// Digest subscriber doesn't get the message directly, but in the digest.
+	distribute(strings.Replace(msg, "Subject: hi", "Subject: second", 1), false)
+	if len(messages) != 2 {
+		t.Fatalf("got %d messages, expected 2", len(messages))
+	}
+	messages = nil
+	err = sendDigest(ctxbg, log, *alias, time.Now())
+	tcheck(t, err, "send digest")
+	if len(messages) != 1 || messages[0].rcpt != remote.String() || !strings.Contains(messages[0].text, "Content-Type: multipart/digest") || !strings.Contains(messages[0].text, "Subject: second") {
+		t.Fatalf("unexpected digest %#v", messages)
+	}
+	messages = nil
+	// Not again within a day.
+	distribute(strings.Replace(msg, "Subject: hi", "Subject: third", 1), false)
+	messages = nil
+	err = sendDigest(ctxbg, log, *alias, time.Now())
+	tcheck(t, err, "send digest")
+	if len(messages) != 0 {
+		t.Fatalf("digest sent again within a day")
+	}
+
+	// Unsubscribe by email, with confirmation.
+	err = Command(ctxbg, log, *alias, "unsubscribe", mailFrom, remote, nil)
+	tcheck(t, err, "unsubscribe")
+	if len(messages) != 1 || !strings.Contains(messages[0].text, "list+unsubscribe-"+token+"@mox.example") {
+		t.Fatalf("unexpected unsubscribe confirmation %#v", messages)
+	}
+	messages = nil
+	err = Command(ctxbg, log, *alias, "unsubscribe-"+token, mailFrom, remote, nil)
+	tcheck(t, err, "confirm unsubscribe")
+	if ok, _ := IsSubscriber(ctxbg, list, remote); ok {
+		t.Fatalf("still subscribed")
+	}
+
+	// Delivery failures remove subscriber after bounce limit.
+	bounceSender := commandAddress(*alias, "bounce-"+thirdToken).Path()
+	queue.ListBounce(log, bounceSender, third.Path())
+	if ok, _ := IsSubscriber(ctxbg, list, third); !ok {
+		t.Fatalf("subscriber removed after first failure")
+	}
+	queue.ListBounce(log, bounceSender, third.Path())
+	if ok, _ := IsSubscriber(ctxbg, list, third); ok {
+		t.Fatalf("subscriber not removed after reaching bounce limit")
+	}
+
+	// Remove remaining subscriber.
+	subs, err = Subscribers(ctxbg, list)
+	tcheck(t, err, "subscribers")
+	if len(subs) != 1 {
+		t.Fatalf("got %d subscribers, expected 1", len(subs))
+	}
+	err = SubscriberRemove(ctxbg, list, subs[0].ID)
+	tcheck(t, err, "remove subscriber")
+	err = SubscriberRemove(ctxbg, list, subs[0].ID)
+	if err == nil {
+		t.Fatalf("removing subscriber twice did not fail")
+	}
+}
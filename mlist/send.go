@@ -0,0 +1,344 @@
+package mlist
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// For tests.
+var queueAdd = queue.Add
+
+// subscriptionURL returns the URL of the web page for managing a subscription, or
+// an empty string if the account web interface isn't available over HTTPS.
+func subscriptionURL(token string) string {
+	u := mox.AccountURL()
+	if u == "" {
+		return ""
+	}
+	return u + "list/subscription?token=" + token
+}
+
+// sendMessage queues a plain text message from the list address with command to
+// an address, typically for confirming a subscription. Replies to the message go
+// to the command address. The SMTP MAIL FROM is the postmaster address, so delivery
+// failures don't end up at the list. The message is DKIM-signed if the domain has
+// DKIM keys.
+func sendMessage(ctx context.Context, log mlog.Log, alias config.Alias, command string, to smtp.Address, subject, text string) error {
+	from := commandAddress(alias, command)
+	postmaster := smtp.NewAddress("postmaster", alias.Domain)
+
+	mf, err := store.CreateMessageTemp(log, "mlist-out")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, mf, "list message")
+
+	msgPrefix, has8bit, smtputf8, messageID, err := composeMessage(ctx, log, mf, from, to, subject, text)
+	if err != nil {
+		return err
+	}
+	fi, err := mf.Stat()
+	if err != nil {
+		return fmt.Errorf("stat message file: %v", err)
+	}
+	size := int64(len(msgPrefix)) + fi.Size()
+
+	qm := queue.MakeMsg(postmaster.Path(), to.Path(), has8bit, smtputf8, size, messageID, []byte(msgPrefix), nil, time.Now(), subject)
+	if err := queueAdd(ctx, log, mox.Conf.Static.Postmaster.Account, mf, qm); err != nil {
+		return fmt.Errorf("queueing message: %v", err)
+	}
+	return nil
+}
+
+func composeMessage(ctx context.Context, log mlog.Log, mf *os.File, from, to smtp.Address, subject, text string) (msgPrefix string, has8bit, smtputf8 bool, messageID string, rerr error) {
+	// We only use smtputf8 if we have to, with a utf-8 localpart. For IDNA, we use ASCII domains.
+	smtputf8 = to.Localpart.IsInternational()
+	xc := message.NewComposer(mf, 100*1024, smtputf8)
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(error); ok && errors.Is(err, message.ErrCompose) {
+			rerr = err
+			return
+		}
+		panic(x)
+	}()
+
+	xc.HeaderAddrs("From", []message.NameAddress{{Address: from}})
+	xc.HeaderAddrs("To", []message.NameAddress{{Address: to}})
+	xc.Subject(subject)
+	messageID = fmt.Sprintf("<%s>", mox.MessageIDGen(xc.SMTPUTF8))
+	xc.Header("Message-Id", messageID)
+	xc.Header("Date", time.Now().Format(message.RFC5322Z))
+	xc.Header("User-Agent", "mox/"+moxvar.Version)
+	xc.Header("Auto-Submitted", "auto-generated")
+	xc.Header("MIME-Version", "1.0")
+
+	textBody, ct, cte := xc.TextPart("plain", text)
+	xc.Header("Content-Type", ct)
+	xc.Header("Content-Transfer-Encoding", cte)
+	xc.Line()
+	_, err := xc.Write(textBody)
+	xc.Checkf(err, "writing text")
+
+	xc.Flush()
+
+	msgPrefix = dkimSign(ctx, log, from, smtputf8, mf)
+	return msgPrefix, xc.Has8bit, xc.SMTPUTF8, messageID, nil
+}
+
+// dkimSign returns DKIM-Signature headers for a message from an address in a list
+// domain, or an empty string if the domain has no DKIM keys or signing failed.
+func dkimSign(ctx context.Context, log mlog.Log, from smtp.Address, smtputf8 bool, msg io.ReaderAt) string {
+	confDom, ok := mox.Conf.Domain(from.Domain)
+	if !ok {
+		return ""
+	}
+	selectors := mox.DKIMSelectors(confDom.DKIM)
+	if len(selectors) == 0 {
+		return ""
+	}
+	dkimHeaders, err := dkim.Sign(ctx, log.Logger, from.Localpart, from.Domain, selectors, smtputf8, msg)
+	if err != nil {
+		log.Errorx("dkim-signing list message, continuing without signature", err)
+		return ""
+	}
+	return dkimHeaders
+}
+
+// listID returns the List-Id value for a list, without <>.
+func listID(alias config.Alias) string {
+	// The list label must be a dot-atom, we replace other characters.
+	label := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, alias.LocalpartStr)
+	return label + "." + alias.Domain.ASCII
+}
+
+// listHeaders returns the headers added to messages sent to subscribers.
+// ../rfc/2919:174 ../rfc/2369:167
+func listHeaders(alias config.Alias, listAddr smtp.Address, smtputf8 bool) string {
+	h := "List-Id: <" + listID(alias) + ">\r\n"
+	h += "List-Post: <mailto:" + listAddr.Path().XString(smtputf8) + ">\r\n"
+	unsub := "<mailto:" + commandAddress(alias, "unsubscribe").Path().XString(smtputf8) + ">"
+	if u := mox.AccountURL(); u != "" {
+		unsub += ", <" + u + "list/>"
+	}
+	h += "List-Unsubscribe: " + unsub + "\r\n"
+	h += "Precedence: list\r\n"
+	return h
+}
+
+// headerFields splits a raw message header into fields, each including its
+// continuation lines and trailing crlf.
+func headerFields(hdr []byte) []string {
+	var l []string
+	for _, line := range strings.SplitAfter(string(hdr), "\n") {
+		if line == "" || line == "\r\n" || line == "\n" {
+			continue
+		}
+		if len(l) > 0 && (line[0] == ' ' || line[0] == '\t') {
+			l[len(l)-1] += line
+		} else {
+			l = append(l, line)
+		}
+	}
+	return l
+}
+
+func fieldName(field string) string {
+	k, _, _ := strings.Cut(field, ":")
+	return strings.ToLower(strings.TrimSpace(k))
+}
+
+// Distribute sends a message accepted for a list to its confirmed subscribers,
+// except the author, and stores it for the archive and digests.
+//
+// The message gets list headers. If rewriteFrom is set, typically because the
+// DMARC policy of the author domain is reject or quarantine, the From header is
+// replaced with the list address, and the original From address set as Reply-To.
+// The result of verifying ARC on the incoming message is added to authResults,
+// which is included in a new ARC set for the outgoing message. The message is
+// DKIM-signed with the keys of the list domain.
+func Distribute(ctx context.Context, log mlog.Log, resolver dns.Resolver, alias config.Alias, authResults message.AuthResults, rewriteFrom, smtputf8 bool, msgFrom smtp.Address, msgFile *os.File) error {
+	listAddr, err := ListAddress(alias)
+	if err != nil {
+		return err
+	}
+	list := listAddr.String()
+
+	st, err := msgFile.Stat()
+	if err != nil {
+		return fmt.Errorf("stat message: %v", err)
+	}
+	data, err := io.ReadAll(io.NewSectionReader(msgFile, 0, st.Size()))
+	if err != nil {
+		return fmt.Errorf("reading message: %v", err)
+	}
+	hdr, err := message.ReadHeaders(bufio.NewReader(bytes.NewReader(data)))
+	if err != nil {
+		return fmt.Errorf("reading message header: %v", err)
+	}
+	body := data[len(hdr)+2:]
+	fields := headerFields(hdr)
+
+	var messageID, subject, fromName string
+	var hasReplyTo bool
+	for _, f := range fields {
+		_, v, _ := strings.Cut(f, ":")
+		v = strings.TrimSpace(v)
+		switch fieldName(f) {
+		case "list-id":
+			// Prevent loops, our own messages coming back.
+			if strings.Contains(v, "<"+listID(alias)+">") {
+				log.Info("not distributing message that already went through list", slog.String("list", list))
+				return nil
+			}
+		case "message-id":
+			messageID = v
+		case "subject":
+			subject, err = (&mime.WordDecoder{}).DecodeHeader(v)
+			if err != nil {
+				subject = v
+			}
+		case "reply-to":
+			hasReplyTo = true
+		case "from":
+			if a, err := mail.ParseAddress(v); err == nil {
+				fromName = a.Name
+			}
+		}
+	}
+
+	subs, err := bstore.QueryDB[Subscriber](ctx, DB).FilterNonzero(Subscriber{List: list}).FilterEqual("Confirmed", true).List()
+	if err != nil {
+		return fmt.Errorf("listing subscribers: %v", err)
+	}
+	var rcpts []Subscriber
+	var digest bool
+	for _, s := range subs {
+		if s.Digest {
+			digest = true
+		} else if s.Address != msgFrom.String() {
+			rcpts = append(rcpts, s)
+		}
+	}
+
+	if alias.List.Archive || digest {
+		m := Message{
+			List:          list,
+			MessageID:     messageID,
+			From:          msgFrom.String(),
+			Subject:       subject,
+			Size:          int64(len(data)),
+			Archive:       alias.List.Archive,
+			DigestPending: digest,
+			Data:          data,
+		}
+		if err := DB.Insert(ctx, &m); err != nil {
+			return fmt.Errorf("storing list message: %v", err)
+		}
+	}
+
+	if len(rcpts) == 0 {
+		return nil
+	}
+
+	// Verify ARC of the incoming message, for the chain validation status of our
+	// new set.
+	arcStatus, _, err := dkim.ARCVerify(ctx, log.Logger, resolver, bytes.NewReader(data))
+	log.Check(err, "verifying arc of list message")
+	authResults.Methods = append(authResults.Methods, message.AuthMethod{Method: "arc", Result: string(arcStatus)})
+
+	// Compose the message for subscribers.
+	var b bytes.Buffer
+	b.WriteString(listHeaders(alias, listAddr, smtputf8))
+	for _, f := range fields {
+		switch fieldName(f) {
+		case "from":
+			if rewriteFrom {
+				name := cmp.Or(fromName, msgFrom.String()) + " via " + list
+				addr := mail.Address{Name: name, Address: listAddr.Pack(smtputf8)}
+				b.WriteString("From: " + addr.String() + "\r\n")
+				if !hasReplyTo {
+					b.WriteString("Reply-To: <" + msgFrom.Pack(smtputf8) + ">\r\n")
+				}
+				continue
+			}
+		case "list-id", "list-post", "list-unsubscribe", "precedence", "return-path":
+			continue
+		}
+		b.WriteString(f)
+	}
+	b.WriteString("\r\n")
+	b.Write(body)
+
+	of, err := store.CreateMessageTemp(log, "mlist-out")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, of, "list message")
+	if _, err := of.Write(b.Bytes()); err != nil {
+		return fmt.Errorf("writing message: %v", err)
+	}
+
+	msgPrefix := dkimSign(ctx, log, listAddr, smtputf8, of)
+	if confDom, ok := mox.Conf.Domain(alias.Domain); ok {
+		if selectors := mox.DKIMSelectors(confDom.DKIM); len(selectors) > 0 {
+			arcHeaders, err := dkim.ARCSeal(ctx, log.Logger, alias.Domain, selectors[0], arcStatus, authResults.Header(), strings.NewReader(msgPrefix+b.String()))
+			if err != nil {
+				log.Errorx("arc-sealing list message, continuing without seal", err)
+			} else {
+				msgPrefix = arcHeaders + msgPrefix
+			}
+		}
+	}
+
+	has8bit := bytes.ContainsFunc(data, func(r rune) bool { return r >= 0x80 })
+	size := int64(len(msgPrefix) + b.Len())
+	qml := make([]queue.Msg, len(rcpts))
+	for i, s := range rcpts {
+		rcpt, err := smtp.ParseAddress(s.Address)
+		if err != nil {
+			return fmt.Errorf("parsing subscriber address: %v", err)
+		}
+		sender := commandAddress(alias, "bounce-"+s.Token)
+		qm := queue.MakeMsg(sender.Path(), rcpt.Path(), has8bit, smtputf8, size, messageID, []byte(msgPrefix), nil, time.Now(), subject)
+		qm.IsListMessage = true
+		qml[i] = qm
+	}
+	if err := queueAdd(ctx, log, mox.Conf.Static.Postmaster.Account, of, qml...); err != nil {
+		return fmt.Errorf("queueing list message: %v", err)
+	}
+	log.Info("list message queued for subscribers", slog.String("list", list), slog.Int("subscribers", len(qml)))
+	return nil
+}
@@ -0,0 +1,316 @@
+package mlist
+
+import (
+	"context"
+	"errors"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/ratelimit"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/webauth"
+)
+
+// Subscribe and unsubscribe requests per remote IP.
+var limiterRequest = sync.OnceValue(func() *ratelimit.Limiter {
+	return &ratelimit.Limiter{
+		WindowLimits: []ratelimit.WindowLimit{
+			{
+				Window: 24 * time.Hour,
+				Limits: [...]int64{20, 60, 180},
+			},
+		},
+	}
+})
+
+// Lists returns the addresses of lists that allow subscribing, sorted.
+func Lists() []string {
+	var l []string
+	for _, name := range mox.Conf.Domains() {
+		dc, ok := mox.Conf.Domain(dns.Domain{ASCII: name})
+		if !ok || dc.Disabled {
+			continue
+		}
+		for _, a := range dc.Aliases {
+			if a.List == nil || !a.List.Subscribe {
+				continue
+			}
+			if addr, err := ListAddress(a); err == nil {
+				l = append(l, addr.String())
+			}
+		}
+	}
+	slices.Sort(l)
+	return l
+}
+
+// Handler returns the handler for the list subscription pages, for requests
+// with paths starting with "/".
+func Handler(isForwarded bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(isForwarded, w, r)
+	})
+}
+
+// page holds the fields for the list templates.
+type page struct {
+	Error      string
+	Message    string
+	Lists      []string
+	List       string
+	Address    string
+	Token      string // For managing a subscription.
+	Subscriber *Subscriber
+}
+
+func handle(isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), mlog.CidKey, mox.Cid())
+	log := pkglog.WithContext(ctx)
+
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("X-Frame-Options", "deny")
+
+	switch r.URL.Path {
+	case "/":
+		switch r.Method {
+		case "GET", "HEAD":
+			render(log, w, http.StatusOK, listTemplate, page{Lists: Lists(), List: r.URL.Query().Get("list")})
+		case "POST":
+			submit(ctx, log, isForwarded, w, r)
+		default:
+			http.Error(w, "405 - method not allowed - use get or post", http.StatusMethodNotAllowed)
+		}
+	case "/subscription":
+		// The GET only shows buttons, so link scanners in mail systems don't confirm or
+		// unsubscribe.
+		switch r.Method {
+		case "GET", "HEAD":
+			token := r.URL.Query().Get("token")
+			p := page{Token: token}
+			if s, err := SubscriptionGet(ctx, token); err != nil {
+				p.Error = err.Error()
+			} else {
+				p.Subscriber = &s
+			}
+			render(log, w, http.StatusOK, subscriptionTemplate, p)
+		case "POST":
+			subscription(ctx, log, isForwarded, w, r)
+		default:
+			http.Error(w, "405 - method not allowed - use get or post", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func render(log mlog.Log, w http.ResponseWriter, status int, t *template.Template, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := t.Execute(w, p)
+	log.Check(err, "writing list page")
+}
+
+// checkOrigin returns whether the request does not come from another site.
+func checkOrigin(isForwarded bool, r *http.Request) bool {
+	_, origin := webauth.RequestOrigin(isForwarded, r)
+	o := r.Header.Get("Origin")
+	return o == "" || o == origin
+}
+
+// submit handles a subscribe or unsubscribe request, sending a confirmation
+// message.
+func submit(ctx context.Context, log mlog.Log, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(isForwarded, r) {
+		http.Error(w, "403 - forbidden - cross-origin request", http.StatusForbidden)
+		return
+	}
+	remoteIP := webauth.RemoteIP(log, isForwarded, r)
+	if remoteIP == nil {
+		http.Error(w, "500 - internal server error - cannot find remote ip", http.StatusInternalServerError)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 16*1024)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "400 - bad request - parsing form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p := page{
+		Lists:   Lists(),
+		List:    r.PostForm.Get("list"),
+		Address: strings.TrimSpace(r.PostForm.Get("address")),
+	}
+	unsubscribe := r.PostForm.Get("action") == "unsubscribe"
+
+	now := time.Now()
+	if !limiterRequest().CanAdd(remoteIP, now, 1) {
+		log.Info("refusing list request due to rate limit", slog.Any("remoteip", remoteIP))
+		p.Error = "too many requests, try again later"
+		render(log, w, http.StatusTooManyRequests, listTemplate, p)
+		return
+	}
+
+	addr, err := smtp.ParseAddress(p.Address)
+	if err != nil {
+		p.Error = "invalid email address: " + err.Error()
+		render(log, w, http.StatusBadRequest, listTemplate, p)
+		return
+	}
+	var alias config.Alias
+	if slices.Contains(p.Lists, p.List) {
+		alias, err = lookupList(p.List)
+	} else {
+		err = ErrUnknownList
+	}
+	if err != nil {
+		p.Error = err.Error()
+		render(log, w, http.StatusBadRequest, listTemplate, p)
+		return
+	}
+	limiterRequest().Add(remoteIP, now, 1)
+
+	if unsubscribe {
+		err = unsubscribeRequest(ctx, log, alias, addr)
+	} else {
+		err = subscribe(ctx, log, alias, addr)
+	}
+	if err != nil {
+		log.Errorx("processing list request", err, slog.String("list", p.List))
+		p.Error = "could not process the request, try again later"
+		render(log, w, http.StatusInternalServerError, listTemplate, p)
+		return
+	}
+	// We don't reveal whether the address is subscribed.
+	render(log, w, http.StatusOK, listTemplate, page{Message: "If applicable, a message with a confirmation link has been sent to " + addr.String() + "."})
+}
+
+// subscription handles confirming, changing and removing a subscription.
+func subscription(ctx context.Context, log mlog.Log, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(isForwarded, r) {
+		http.Error(w, "403 - forbidden - cross-origin request", http.StatusForbidden)
+		return
+	}
+	remoteIP := webauth.RemoteIP(log, isForwarded, r)
+	if remoteIP == nil {
+		http.Error(w, "500 - internal server error - cannot find remote ip", http.StatusInternalServerError)
+		return
+	}
+	t0 := time.Now()
+	if !mox.LimiterFailedAuth.CanAdd(remoteIP, t0, 1) {
+		http.Error(w, "429 - too many attempts", http.StatusTooManyRequests)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1024)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "400 - bad request - parsing form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	token := r.PostForm.Get("token")
+	var msg string
+	var err error
+	switch r.PostForm.Get("action") {
+	case "confirm":
+		_, err = Confirm(ctx, log, token)
+		msg = "Your subscription has been confirmed."
+	case "digest", "nodigest":
+		digest := r.PostForm.Get("action") == "digest"
+		err = DigestSave(ctx, token, digest)
+		msg = "You will now receive each message."
+		if digest {
+			msg = "You will now receive a daily digest."
+		}
+	case "unsubscribe":
+		_, err = Unsubscribe(ctx, log, token)
+		msg = "You have been unsubscribed."
+	default:
+		http.Error(w, "400 - bad request - unknown action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		if errors.Is(err, ErrUnknownToken) {
+			mox.LimiterFailedAuth.Add(remoteIP, t0, 1)
+		}
+		render(log, w, http.StatusBadRequest, subscriptionTemplate, page{Error: err.Error()})
+		return
+	}
+	p := page{Message: msg, Token: token}
+	if s, err := SubscriptionGet(ctx, token); err == nil {
+		p.Subscriber = &s
+	}
+	render(log, w, http.StatusOK, subscriptionTemplate, p)
+}
+
+const pageStyle = `<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+label { display: block; margin: 1ex 0; }
+input { width: 100%; box-sizing: border-box; }
+.error { background-color: #ff7443; padding: .5em; border-radius: 3px; }
+.message { background-color: #1dea20; padding: .5em; border-radius: 3px; }
+</style>`
+
+var listTemplate = template.Must(template.New("list").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<meta name="viewport" content="width=device-width, initial-scale=1" />
+		<title>Mailing lists</title>
+		` + pageStyle + `
+	</head>
+	<body>
+		<h1>Mailing lists</h1>
+		{{ if .Error }}<p class="error">Error: {{ .Error }}</p>{{ end }}
+		{{ if .Message }}<p class="message">{{ .Message }}</p>{{ else if .Lists }}
+		<form method="post" action="./">
+			<label>List<br/><select name="list">{{ range .Lists }}<option{{ if eq . $.List }} selected{{ end }}>{{ . }}</option>{{ end }}</select></label>
+			<label>Email address<br/><input name="address" type="email" value="{{ .Address }}" required autocomplete="email" /></label>
+			<p>
+				<button type="submit" name="action" value="subscribe">Subscribe</button>
+				<button type="submit" name="action" value="unsubscribe">Unsubscribe</button>
+			</p>
+		</form>
+		{{ else }}
+		<p>No lists available for subscribing.</p>
+		{{ end }}
+	</body>
+</html>
+`))
+
+var subscriptionTemplate = template.Must(template.New("subscription").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<meta name="viewport" content="width=device-width, initial-scale=1" />
+		<title>Subscription</title>
+		` + pageStyle + `
+	</head>
+	<body>
+		<h1>Subscription</h1>
+		{{ if .Error }}<p class="error">Error: {{ .Error }}</p>{{ end }}
+		{{ if .Message }}<p class="message">{{ .Message }}</p>{{ end }}
+		{{ with .Subscriber }}
+		<p>Address {{ .Address }} on list {{ .List }}.</p>
+		<form method="post" action="subscription">
+			<input type="hidden" name="token" value="{{ $.Token }}" />
+			<p>
+				{{ if not .Confirmed }}<button type="submit" name="action" value="confirm">Confirm subscription</button>{{ end }}
+				{{ if .Digest }}<button type="submit" name="action" value="nodigest">Receive each message</button>{{ else }}<button type="submit" name="action" value="digest">Receive daily digest</button>{{ end }}
+				<button type="submit" name="action" value="unsubscribe">Unsubscribe</button>
+			</p>
+		</form>
+		{{ end }}
+	</body>
+</html>
+`))
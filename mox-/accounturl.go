@@ -0,0 +1,25 @@
+package mox
+
+import (
+	"cmp"
+	"maps"
+	"slices"
+)
+
+// AccountURL returns the https URL of the account web interface, ending with a
+// slash, of the first listener with AccountHTTPS enabled. An empty string is
+// returned if no listener serves the account web interface over HTTPS.
+func AccountURL() string {
+	for _, name := range slices.Sorted(maps.Keys(Conf.Static.Listeners)) {
+		l := Conf.Static.Listeners[name]
+		if !l.AccountHTTPS.Enabled {
+			continue
+		}
+		host := l.HostnameDomain
+		if host.IsZero() {
+			host = Conf.Static.HostnameDomain
+		}
+		return "https://" + host.ASCII + cmp.Or(l.AccountHTTPS.Path, "/")
+	}
+	return ""
+}
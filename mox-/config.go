@@ -9,16 +9,20 @@ import (
 	"crypto/elliptic"
 	cryptorand "crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
 	"net"
 	"net/http"
+	"net/mail"
 	"net/url"
 	"os"
 	"os/user"
@@ -40,11 +44,13 @@ import (
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/dnsprovision"
 	"github.com/mjl-/mox/message"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/moxio"
 	"github.com/mjl-/mox/mtasts"
 	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/tlsfp"
 )
 
 var pkglog = mlog.New("mox", nil)
@@ -273,6 +279,72 @@ func (c *Config) Account(name string) (acc config.Account, ok bool) {
 	return
 }
 
+// HostWeb returns the web interface customization for a request with host (as in
+// the Host header, optionally with port): from the configured domain matching host,
+// or else the closest parent domain. Only a domain with a Web config is returned.
+func (c *Config) HostWeb(host string) (web config.DomainWeb, ok bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	d, err := dns.ParseDomain(host)
+	if err != nil {
+		return config.DomainWeb{}, false
+	}
+	c.withDynamicLock(func() {
+		name := d.Name()
+		for {
+			if dom, exists := c.Dynamic.Domains[name]; exists {
+				if dom.Web != nil {
+					web, ok = *dom.Web, true
+				}
+				return
+			}
+			_, name, _ = strings.Cut(name, ".")
+			if !strings.Contains(name, ".") {
+				return
+			}
+		}
+	})
+	return
+}
+
+// AccountWeb returns the web interface customization for an account, from its
+// default domain.
+func (c *Config) AccountWeb(accountName string) (web config.DomainWeb, ok bool) {
+	c.withDynamicLock(func() {
+		acc, exists := c.Dynamic.Accounts[accountName]
+		if !exists {
+			return
+		}
+		if dom, exists := c.Dynamic.Domains[acc.DNSDomain.Name()]; exists && dom.Web != nil {
+			web, ok = *dom.Web, true
+		}
+	})
+	return
+}
+
+// WebFeatureDisabled returns whether a feature of the web interfaces, one of
+// config.WebFeatures, is disabled for the account through the Web config of its
+// default domain.
+func (c *Config) WebFeatureDisabled(accountName, feature string) bool {
+	web, ok := c.AccountWeb(accountName)
+	return ok && slices.Contains(web.DisabledFeatures, feature)
+}
+
+func (c *Config) AdminUser(name string) (au config.AdminUser, ok bool) {
+	c.withDynamicLock(func() {
+		au, ok = c.Dynamic.AdminUsers[name]
+	})
+	return
+}
+
+func (c *Config) AdminUsers() (m map[string]config.AdminUser) {
+	c.withDynamicLock(func() {
+		m = maps.Clone(c.Dynamic.AdminUsers)
+	})
+	return
+}
+
 func (c *Config) AccountDestination(addr string) (accDest AccountDestination, alias *config.Alias, ok bool) {
 	c.withDynamicLock(func() {
 		accDest, ok = c.AccountDestinationsLocked[addr]
@@ -300,6 +372,24 @@ func (c *Config) Routes(accountName string, domain dns.Domain) (accountRoutes, d
 	return
 }
 
+// TLSPolicies returns the configured TLS policies for direct delivery from the
+// queue.
+func (c *Config) TLSPolicies() (l []config.TLSPolicy) {
+	c.withDynamicLock(func() {
+		l = c.Dynamic.TLSPolicies
+	})
+	return
+}
+
+// RetrySchedules returns the configured schedules for retrying deliveries from
+// the queue.
+func (c *Config) RetrySchedules() (l []config.RetrySchedule) {
+	c.withDynamicLock(func() {
+		l = c.Dynamic.RetrySchedules
+	})
+	return
+}
+
 func (c *Config) IsClientSettingsDomain(d dns.Domain) (is bool) {
 	c.withDynamicLock(func() {
 		_, is = c.Dynamic.ClientSettingDomains[d]
@@ -351,6 +441,22 @@ func (c *Config) allowACMEHosts(log mlog.Log, checkACMEHosts bool) {
 				}
 			}
 
+			if l.WKDHTTPS.Enabled && !l.WKDHTTPS.NonTLS {
+				if d, err := dns.ParseDomain("openpgpkey." + dom.Domain.ASCII); err != nil {
+					log.Errorx("parsing openpgpkey domain", err, slog.Any("domain", dom.Domain))
+				} else {
+					hostnames[d] = struct{}{}
+				}
+			}
+
+			if l.BIMIHTTPS.Enabled && dom.BIMI != nil && !l.BIMIHTTPS.NonTLS {
+				if d, err := dns.ParseDomain("bimi." + dom.Domain.ASCII); err != nil {
+					log.Errorx("parsing bimi domain", err, slog.Any("domain", dom.Domain))
+				} else {
+					hostnames[d] = struct{}{}
+				}
+			}
+
 			if dom.ClientSettingsDomain != "" {
 				hostnames[dom.ClientSettingsDNSDomain] = struct{}{}
 			}
@@ -377,6 +483,17 @@ func (c *Config) allowACMEHosts(log mlog.Log, checkACMEHosts bool) {
 	}
 }
 
+// acmeDNS01Zone returns the longest zone that contains host.
+func acmeDNS01Zone(zones []dns.Domain, host dns.Domain) (dns.Domain, bool) {
+	var zone dns.Domain
+	for _, z := range zones {
+		if (host == z || strings.HasSuffix(host.ASCII, "."+z.ASCII)) && len(z.ASCII) > len(zone.ASCII) {
+			zone = z
+		}
+	}
+	return zone, zone.ASCII != ""
+}
+
 // todo future: write config parsing & writing code that can read a config and remembers the exact tokens including newlines and comments, and can write back a modified file. the goal is to be able to write a config file automatically (after changing fields through the ui), but not loose comments and whitespace, to still get useful diffs for storing the config in a version control system.
 
 // WriteDynamicLocked prepares an updated internal state for the new dynamic
@@ -617,6 +734,232 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 		c.HostTLSRPT.ParsedLocalpart = tlsrptLocalpart
 	}
 
+	if fr := c.OutgoingDMARCFailureReports; fr != nil && fr.MaxPerDay < 0 {
+		addErrorf("outgoing dmarc failure reports: max per day must be >= 0")
+	}
+
+	for i := range c.Exemptions {
+		e := &c.Exemptions[i]
+		if len(e.Networks) == 0 && len(e.Accounts) == 0 {
+			addErrorf("exemption %d: at least one network or account required", i)
+		}
+		if !e.JunkFilter && !e.DNSBL && !e.Delays && !e.RateLimits {
+			addErrorf("exemption %d: at least one of junkfilter, dnsbl, delays and ratelimits required", i)
+		}
+		e.IPNets = nil
+		for _, s := range e.Networks {
+			if ip := net.ParseIP(s); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					ip = ip.To4()
+					bits = 32
+				}
+				e.IPNets = append(e.IPNets, net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			} else if _, ipnet, err := net.ParseCIDR(s); err != nil {
+				addErrorf("exemption %d: parsing network %q: %v", i, s, err)
+			} else {
+				e.IPNets = append(e.IPNets, *ipnet)
+			}
+		}
+	}
+
+	c.TrustedARCSealerDomains = nil
+	for _, s := range c.TrustedARCSealers {
+		d, err := dns.ParseDomain(s)
+		if err != nil {
+			addErrorf("parsing trusted arc sealer domain %q: %v", s, err)
+			continue
+		}
+		c.TrustedARCSealerDomains = append(c.TrustedARCSealerDomains, d)
+	}
+
+	if pt := c.PostmasterTools; pt != nil {
+		n := 0
+		for _, s := range []string{pt.GoogleClientID, pt.GoogleClientSecret, pt.GoogleRefreshToken} {
+			if s != "" {
+				n++
+			}
+		}
+		if n != 0 && n != 3 {
+			addErrorf("postmaster tools: either all or none of GoogleClientID, GoogleClientSecret and GoogleRefreshToken must be set")
+		}
+	}
+
+	if sf := c.SpamFilter; sf != nil {
+		if (sf.Rspamd == "") == (sf.Spamd == "") {
+			addErrorf("spam filter: exactly one of rspamd and spamd must be set")
+		}
+		if sf.Rspamd != "" {
+			if u, err := url.Parse(sf.Rspamd); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				addErrorf("spam filter: rspamd must be an http or https url")
+			}
+		}
+		if sf.Spamd != "" {
+			if _, _, err := net.SplitHostPort(sf.Spamd); err != nil {
+				addErrorf("spam filter: spamd must be of the form host:port: %v", err)
+			}
+		}
+		switch sf.Policy {
+		case "", config.SpamFilterPolicyEither, config.SpamFilterPolicyBoth, config.SpamFilterPolicyExternal:
+		default:
+			addErrorf("spam filter: unknown policy %q, must be either, both or external", sf.Policy)
+		}
+		if sf.RejectScore != 0 && sf.QuarantineScore != 0 && sf.QuarantineScore >= sf.RejectScore {
+			addErrorf("spam filter: quarantine score must be lower than reject score")
+		}
+	}
+
+	if oa := c.OutgoingAnomalies; oa != nil {
+		switch oa.Action {
+		case "", config.OutgoingAnomalyAlert, config.OutgoingAnomalyThrottle, config.OutgoingAnomalySuspend:
+		default:
+			addErrorf("outgoing anomalies: unknown action %q, must be alert, throttle or suspend", oa.Action)
+		}
+		if oa.VolumeFactor < 0 || oa.VolumeMinimum < 0 || oa.FailedMinimum < 0 {
+			addErrorf("outgoing anomalies: volume factor, volume minimum and failed minimum must be >= 0")
+		}
+		if oa.FailedPercentage < 0 || oa.FailedPercentage > 100 {
+			addErrorf("outgoing anomalies: failed percentage must be between 0 and 100")
+		}
+	}
+
+	if mc := c.MessageCompression; mc != nil {
+		if mc.Level < 0 || mc.Level > 9 {
+			addErrorf("message compression: level must be between 1 and 9, or 0 for the default")
+		}
+		if mc.MinSize < 0 {
+			addErrorf("message compression: minimum size must be >= 0")
+		}
+	}
+	if md := c.MessageDeduplication; md != nil && md.MinSize < 0 {
+		addErrorf("message deduplication: minimum size must be >= 0")
+	}
+
+	if cr := c.ContentReputation; cr != nil {
+		parseDomains := func(kind string, l []string) (r []dns.Domain) {
+			for _, s := range l {
+				d, err := dns.ParseDomain(s)
+				if err != nil {
+					addErrorf("content reputation: parsing %s %q: %v", kind, s, err)
+					continue
+				}
+				r = append(r, d)
+			}
+			return r
+		}
+		cr.URIBLZones = parseDomains("uribl zone", cr.URIBLs)
+		cr.HashBLZones = parseDomains("hashbl zone", cr.HashBLs)
+		cr.BlockedDomainsParsed = parseDomains("blocked domain", cr.BlockedDomains)
+		if cr.MaxURLs < 0 {
+			addErrorf("content reputation: max urls must be >= 0")
+		}
+		if cr.CacheTTL < 0 {
+			addErrorf("content reputation: cache ttl must be >= 0")
+		}
+		cr.Hashes = map[string]struct{}{}
+		if cr.HashFile != "" {
+			buf, err := os.ReadFile(configDirPath(configFile, cr.HashFile))
+			if err != nil {
+				addErrorf("content reputation: reading hash file: %v", err)
+			}
+			for i, line := range strings.Split(string(buf), "\n") {
+				line = strings.TrimSpace(line)
+				if line == "" || strings.HasPrefix(line, "#") {
+					continue
+				}
+				line = strings.ToLower(line)
+				if h, err := hex.DecodeString(line); err != nil || len(h) != sha256.Size {
+					addErrorf("content reputation: hash file line %d: not a hexadecimal sha-256 hash", i+1)
+					continue
+				}
+				cr.Hashes[line] = struct{}{}
+			}
+		}
+	}
+
+	if oc := c.OIDC; oc != nil {
+		if u, err := url.Parse(oc.Issuer); err != nil || u.Scheme != "https" || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+			addErrorf("oidc: issuer must be an https url without query or fragment")
+		}
+		if oc.ClientID == "" {
+			addErrorf("oidc: client id must be set")
+		}
+	}
+
+	if lc := c.LDAP; lc != nil {
+		if u, err := url.Parse(lc.URL); err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			addErrorf("ldap: url must be of the form ldap://host[:port] or ldaps://host[:port]")
+		} else if lc.StartTLS && u.Scheme != "ldap" {
+			addErrorf("ldap: starttls can only be used with ldap:// urls")
+		}
+		if lc.BaseDN == "" {
+			addErrorf("ldap: base dn must be set")
+		}
+		if lc.Filter != "" && (strings.Count(lc.Filter, "%s") != 1 || strings.Count(lc.Filter, "%") != 1) {
+			addErrorf("ldap: filter must contain %%s exactly once, and no other %% characters")
+		}
+	}
+
+	if pc := c.ProvisioningAPI; pc != nil && pc.WebhookURL != "" {
+		if u, err := url.Parse(pc.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addErrorf("provisioning api: webhook url must be an http or https url")
+		}
+	}
+
+	if sc := c.Signup; sc != nil && sc.CaptchaVerifyURL != "" {
+		if u, err := url.Parse(sc.CaptchaVerifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addErrorf("signup: captcha verify url must be an http or https url")
+		}
+		if sc.CaptchaSecret == "" {
+			addErrorf("signup: captcha secret required with captcha verify url")
+		}
+	}
+	for name, p := range c.DNSProviders {
+		addProviderErrorf := func(format string, args ...any) {
+			addErrorf("dns provider %s: %s", name, fmt.Sprintf(format, args...))
+		}
+
+		n := 0
+		if rc := p.RFC2136; rc != nil {
+			n++
+			if _, _, err := net.SplitHostPort(rc.Server); err != nil {
+				addProviderErrorf("server must be of the form host:port: %v", err)
+			}
+			if _, err := dns.ParseDomain(strings.TrimSuffix(rc.TSIGKeyName, ".")); err != nil {
+				addProviderErrorf("parsing tsig key name: %v", err)
+			}
+			if buf, err := base64.StdEncoding.DecodeString(rc.TSIGSecret); err != nil || len(buf) == 0 {
+				addProviderErrorf("tsig secret must be non-empty base64")
+			}
+			switch rc.TSIGAlgorithm {
+			case "", "hmac-sha256", "hmac-sha512":
+			default:
+				addProviderErrorf("unknown tsig algorithm %q", rc.TSIGAlgorithm)
+			}
+		}
+		if p.Cloudflare != nil {
+			n++
+		}
+		if p.DeSEC != nil {
+			n++
+		}
+		if p.Route53 != nil {
+			n++
+		}
+		if n != 1 {
+			addProviderErrorf("must have exactly one provider type")
+		}
+	}
+
+	if c.QuotaSoftPercent < 0 || c.QuotaSoftPercent > 100 {
+		addErrorf("quota soft percent must be between 0 and 100")
+	}
+	for _, pct := range c.QuotaWarnPercentages {
+		if pct <= 0 || pct > 100 {
+			addErrorf("quota warn percentage %d must be between 1 and 100", pct)
+		}
+	}
+
 	// Return private key for host name for use with an ACME. Used to return the same
 	// private key as pre-generated for use with DANE, with its public key in DNS.
 	// We only use this key for Listener's that have this ACME configured, and for
@@ -671,6 +1014,10 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 					slog.String("acmename", acmeName),
 					slog.String("host", host),
 					slog.Any("keytype", keyType))
+				if acme, ok := Conf.Static.ACME[acmeName]; ok && acme.Manager != nil {
+					resolver := dns.StrictResolver{Pkg: "autotls", Log: log.Logger}
+					key = daneRolloverKey(log, resolver, acme.Manager, host, keyType, key, acme.BlockDANERollover)
+				}
 				return key, nil
 			}
 			log.Debug("generating new private key for certificate for host",
@@ -711,6 +1058,39 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 			}
 		}
 
+		if d := acme.DNS01; d != nil {
+			if _, ok := c.DNSProviders[d.Provider]; !ok {
+				addAcmeErrorf("dns-01: unknown dns provider %q", d.Provider)
+			}
+			if len(d.Zones) == 0 {
+				addAcmeErrorf("dns-01: at least one zone required")
+			}
+			d.DNSZones = nil
+			for _, z := range d.Zones {
+				zone, err := dns.ParseDomain(z)
+				if err != nil {
+					addAcmeErrorf("dns-01: parsing zone %q: %v", z, err)
+					continue
+				}
+				d.DNSZones = append(d.DNSZones, zone)
+			}
+			d.WildcardDomains = nil
+			for _, w := range d.Wildcards {
+				wd, err := dns.ParseDomain(w)
+				if err != nil {
+					addAcmeErrorf("dns-01: parsing wildcard domain %q: %v", w, err)
+					continue
+				}
+				if _, ok := acmeDNS01Zone(d.DNSZones, wd); !ok {
+					addAcmeErrorf("dns-01: wildcard domain %s not in any zone", wd)
+				}
+				d.WildcardDomains = append(d.WildcardDomains, wd)
+			}
+			if d.PropagationDelay < 0 {
+				addAcmeErrorf("dns-01: propagation delay must not be negative")
+			}
+		}
+
 		if checkOnly {
 			continue
 		}
@@ -723,6 +1103,30 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 		}
 		acme.Manager = manager
 
+		if d := acme.DNS01; d != nil && manager != nil {
+			if provider, err := dnsprovision.New(c.DNSProviders[d.Provider]); err != nil {
+				addAcmeErrorf("dns-01: dns provider: %v", err)
+			} else {
+				zones := d.DNSZones
+				setTXT := func(ctx context.Context, name string, values []string) error {
+					host, err := dns.ParseDomain(name)
+					if err != nil {
+						return fmt.Errorf("parsing name: %v", err)
+					}
+					zone, ok := acmeDNS01Zone(zones, host)
+					if !ok {
+						return fmt.Errorf("no dns zone configured for %s", host)
+					}
+					return provider.SetRecords(ctx, zone, host.ASCII, "TXT", 60, values)
+				}
+				delay := d.PropagationDelay
+				if delay == 0 {
+					delay = 30 * time.Second
+				}
+				manager.SetDNS01(&autotls.DNS01{SetTXT: setTXT, PropagationDelay: delay, Wildcards: d.WildcardDomains})
+			}
+		}
+
 		// Help configurations from older quickstarts.
 		if acme.IssuerDomainName == "" && acme.DirectoryURL == "https://acme-v02.api.letsencrypt.org/directory" {
 			acme.IssuerDomainName = "letsencrypt.org"
@@ -745,8 +1149,20 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 			l.HostnameDomain = d
 		}
 		if l.TLS != nil {
-			if l.TLS.ACME != "" && len(l.TLS.KeyCerts) != 0 {
-				addListenerErrorf("cannot have ACME and static key/certificates")
+			for i, src := range l.TLS.CertSources {
+				if (src.Directory != "") == (src.Vault != nil) {
+					addListenerErrorf("certificate source %d: must have exactly one of directory and vault", i)
+				} else if v := src.Vault; v != nil {
+					if u, err := url.Parse(v.Address); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+						addListenerErrorf("certificate source %d: vault address must be an http or https url", i)
+					}
+					if len(v.Paths) == 0 {
+						addListenerErrorf("certificate source %d: vault needs at least one path", i)
+					}
+				}
+			}
+			if l.TLS.ACME != "" && (len(l.TLS.KeyCerts) != 0 || len(l.TLS.CertSources) != 0) {
+				addListenerErrorf("cannot have ACME and static key/certificates or certificate sources")
 			} else if l.TLS.ACME != "" {
 				acme, ok := c.ACME[l.TLS.ACME]
 				if !ok {
@@ -775,14 +1191,14 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 				}
 				l.TLS.Config = tlsconfig
 				l.TLS.ConfigFallback = tlsconfigFallback
-			} else if len(l.TLS.KeyCerts) != 0 {
+			} else if len(l.TLS.KeyCerts) != 0 || len(l.TLS.CertSources) != 0 {
 				if doLoadTLSKeyCerts {
 					if err := loadTLSKeyCerts(configFile, "listener "+name, l.TLS); err != nil {
 						addListenerErrorf("%w", err)
 					}
 				}
 			} else {
-				addListenerErrorf("cannot have TLS config without ACME and without static keys/certificates")
+				addListenerErrorf("cannot have TLS config without ACME and without static keys/certificates or certificate sources")
 			}
 			for _, privKeyFile := range l.TLS.HostPrivateKeyFiles {
 				keyPath := configDirPath(configFile, privKeyFile)
@@ -858,6 +1274,8 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 			needtls("AdminHTTPS", l.AdminHTTPS.Enabled)
 			needtls("AutoconfigHTTPS", l.AutoconfigHTTPS.Enabled && !l.AutoconfigHTTPS.NonTLS)
 			needtls("MTASTSHTTPS", l.MTASTSHTTPS.Enabled && !l.MTASTSHTTPS.NonTLS)
+			needtls("WKDHTTPS", l.WKDHTTPS.Enabled && !l.WKDHTTPS.NonTLS)
+			needtls("BIMIHTTPS", l.BIMIHTTPS.Enabled && !l.BIMIHTTPS.NonTLS)
 			needtls("WebserverHTTPS", l.WebserverHTTPS.Enabled)
 			if len(needsTLS) > 0 {
 				addListenerErrorf("no tls config specified, but requires tls for %s", strings.Join(needsTLS, ", "))
@@ -897,6 +1315,14 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 			}
 			l.SMTP.DNSBLZones = append(l.SMTP.DNSBLZones, d)
 		}
+		for _, fps := range [][]string{l.SMTP.TLSFingerprintsBlocked, l.SMTP.TLSFingerprintsSuspicious} {
+			for i, fp := range fps {
+				fps[i] = strings.ToLower(fp)
+				if !tlsfp.Valid(fps[i]) {
+					addListenerErrorf("invalid tls fingerprint %q, must be in ja3 or ja4 form", fp)
+				}
+			}
+		}
 		if l.IPsNATed && len(l.NATIPs) > 0 {
 			addListenerErrorf("both IPsNATed and NATIPs configued (remove deprecated IPsNATed)")
 		}
@@ -928,6 +1354,10 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 		l.WebmailHTTPS.Path = cleanPath("WebmailHTTPS", l.WebmailHTTPS.Enabled, l.WebmailHTTPS.Path)
 		l.WebAPIHTTP.Path = cleanPath("WebAPIHTTP", l.WebAPIHTTP.Enabled, l.WebAPIHTTP.Path)
 		l.WebAPIHTTPS.Path = cleanPath("WebAPIHTTPS", l.WebAPIHTTPS.Enabled, l.WebAPIHTTPS.Path)
+		l.CalDAVHTTP.Path = cleanPath("CalDAVHTTP", l.CalDAVHTTP.Enabled, l.CalDAVHTTP.Path)
+		l.CalDAVHTTPS.Path = cleanPath("CalDAVHTTPS", l.CalDAVHTTPS.Enabled, l.CalDAVHTTPS.Path)
+		l.CardDAVHTTP.Path = cleanPath("CardDAVHTTP", l.CardDAVHTTP.Enabled, l.CardDAVHTTP.Path)
+		l.CardDAVHTTPS.Path = cleanPath("CardDAVHTTPS", l.CardDAVHTTPS.Enabled, l.CardDAVHTTPS.Path)
 		c.Listeners[name] = l
 	}
 	if haveUnspecifiedSMTPListener {
@@ -1056,6 +1486,14 @@ func PrepareStaticConfig(ctx context.Context, log mlog.Log, configFile string, c
 		if t.DisableIPv6 {
 			t.IPFamily = "ip4"
 		}
+		switch t.PreferIPFamily {
+		case "", "ipv4", "ipv6":
+		default:
+			addTransportErrorf("unknown PreferIPFamily %q, must be ipv4 or ipv6", t.PreferIPFamily)
+		}
+		if t.HappyEyeballsDelay < 0 {
+			addTransportErrorf("HappyEyeballsDelay must be >= 0")
+		}
 	}
 
 	for name, t := range c.Transports {
@@ -1140,6 +1578,13 @@ func ParseDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string, s
 	return c, fi.ModTime(), accDests, aliases, errs
 }
 
+// CheckDynamicConfig validates a dynamic config as WriteDynamicLocked would,
+// without writing or activating it. Fields derived from the config are set in c.
+func CheckDynamicConfig(ctx context.Context, log mlog.Log, c *config.Dynamic) []error {
+	_, _, errs := prepareDynamicConfig(ctx, log, ConfigDynamicPath, Conf.Static, c)
+	return errs
+}
+
 func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string, static config.Static, c *config.Dynamic) (accDests map[string]AccountDestination, aliases map[string]config.Alias, errs []error) {
 	addErrorf := func(format string, args ...any) {
 		errs = append(errs, fmt.Errorf(format, args...))
@@ -1179,41 +1624,44 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 		accDests[addrFull] = AccountDestination{false, static.HostTLSRPT.ParsedLocalpart, static.HostTLSRPT.Account, dest}
 	}
 
-	var haveSTSListener, haveWebserverListener bool
+	var haveSTSListener, haveBIMIListener, haveWebserverListener bool
 	for _, l := range static.Listeners {
 		if l.MTASTSHTTPS.Enabled {
 			haveSTSListener = true
 		}
+		if l.BIMIHTTPS.Enabled {
+			haveBIMIListener = true
+		}
 		if l.WebserverHTTP.Enabled || l.WebserverHTTPS.Enabled {
 			haveWebserverListener = true
 		}
 	}
 
-	checkRoutes := func(descr string, routes []config.Route) {
-		parseRouteDomains := func(l []string) []string {
-			var r []string
-			for _, e := range l {
-				if e == "." {
-					r = append(r, e)
-					continue
-				}
-				prefix := ""
-				if strings.HasPrefix(e, ".") {
-					prefix = "."
-					e = e[1:]
-				}
-				d, err := dns.ParseDomain(e)
-				if err != nil {
-					addErrorf("%s: invalid domain %s: %v", descr, e, err)
-				}
-				r = append(r, prefix+d.ASCII)
+	parseRouteDomains := func(descr string, l []string) []string {
+		var r []string
+		for _, e := range l {
+			if e == "." {
+				r = append(r, e)
+				continue
 			}
-			return r
+			prefix := ""
+			if strings.HasPrefix(e, ".") {
+				prefix = "."
+				e = e[1:]
+			}
+			d, err := dns.ParseDomain(e)
+			if err != nil {
+				addErrorf("%s: invalid domain %s: %v", descr, e, err)
+			}
+			r = append(r, prefix+d.ASCII)
 		}
+		return r
+	}
 
+	checkRoutes := func(descr string, routes []config.Route) {
 		for i := range routes {
-			routes[i].FromDomainASCII = parseRouteDomains(routes[i].FromDomain)
-			routes[i].ToDomainASCII = parseRouteDomains(routes[i].ToDomain)
+			routes[i].FromDomainASCII = parseRouteDomains(descr, routes[i].FromDomain)
+			routes[i].ToDomainASCII = parseRouteDomains(descr, routes[i].ToDomain)
 			var ok bool
 			routes[i].ResolvedTransport, ok = static.Transports[routes[i].Transport]
 			if !ok {
@@ -1224,6 +1672,194 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 
 	checkRoutes("global routes", c.Routes)
 
+	checkOutgoingHeaderRules := func(descr string, r *config.OutgoingHeaderRules) {
+		if r == nil {
+			return
+		}
+		for _, k := range r.Remove {
+			if k == "" || strings.ContainsAny(strings.TrimSuffix(k, "*"), ": *\t\r\n") {
+				addErrorf("%s: invalid header key %q to remove", descr, k)
+			}
+		}
+		if strings.ContainsAny(r.FromDisplayName, "\r\n") {
+			addErrorf("%s: from display name cannot contain newlines", descr)
+		}
+		if r.ReplyTo != "" {
+			if _, err := mail.ParseAddress(r.ReplyTo); err != nil {
+				addErrorf("%s: parsing reply-to address %q: %v", descr, r.ReplyTo, err)
+			}
+		}
+		for _, h := range r.Add {
+			k, v, ok := strings.Cut(h, ":")
+			if !ok || k == "" || strings.ContainsAny(k, " \t\r\n") || strings.ContainsAny(v, "\r\n") {
+				addErrorf("%s: invalid header field %q to add, must be of the form \"Key: value\"", descr, h)
+			}
+		}
+	}
+
+	checkOutgoingFooter := func(descr string, f *config.OutgoingFooter) {
+		if f == nil {
+			return
+		}
+		if len(f.Text) == 0 {
+			addErrorf("%s: footer must have at least one line of text", descr)
+		}
+		for _, line := range f.Text {
+			if strings.ContainsAny(line, "\r\n") {
+				addErrorf("%s: footer text line cannot contain newlines", descr)
+			}
+		}
+		if strings.ContainsAny(f.HTML, "\r\n") {
+			addErrorf("%s: footer html cannot contain newlines", descr)
+		}
+	}
+
+	checkMessageTemplates := func(descr string, l []config.MessageTemplate) {
+		names := map[string]bool{}
+		for i, t := range l {
+			if t.Name == "" {
+				addErrorf("%s: template %d: missing name", descr, i+1)
+			} else if names[t.Name] {
+				addErrorf("%s: duplicate template name %q", descr, t.Name)
+			}
+			names[t.Name] = true
+			if strings.ContainsAny(t.Subject, "\r\n") {
+				addErrorf("%s: template %q: subject cannot contain newlines", descr, t.Name)
+			}
+			for _, line := range t.Text {
+				if strings.ContainsAny(line, "\r\n") {
+					addErrorf("%s: template %q: text line cannot contain newlines", descr, t.Name)
+				}
+			}
+		}
+	}
+
+	checkIdentities := func(descr string, l []config.Identity) {
+		var haveDefault bool
+		seen := map[string]bool{}
+		for i, ident := range l {
+			a, err := smtp.ParseAddress(ident.Address)
+			if err != nil {
+				addErrorf("%s: identity %d: parsing address %q: %v", descr, i+1, ident.Address, err)
+				continue
+			}
+			k := ident.DisplayName + "\n" + a.String()
+			if seen[k] {
+				addErrorf("%s: duplicate identity for address %q and display name %q", descr, ident.Address, ident.DisplayName)
+			}
+			seen[k] = true
+			if ident.Default {
+				if haveDefault {
+					addErrorf("%s: at most one identity can be the default", descr)
+				}
+				haveDefault = true
+			}
+			if strings.ContainsAny(ident.DisplayName, "\r\n") {
+				addErrorf("%s: identity %q: display name cannot contain newlines", descr, ident.Address)
+			}
+			for _, line := range ident.Signature {
+				if strings.ContainsAny(line, "\r\n") {
+					addErrorf("%s: identity %q: signature line cannot contain newlines", descr, ident.Address)
+				}
+			}
+			if strings.ContainsAny(ident.SignatureHTML, "\r\n") {
+				addErrorf("%s: identity %q: signature html cannot contain newlines", descr, ident.Address)
+			}
+			switch ident.ReplyQuoting {
+			case "", "bottom", "top":
+			default:
+				addErrorf("%s: identity %q: unknown reply quoting %q, must be empty, bottom or top", descr, ident.Address, ident.ReplyQuoting)
+			}
+			if ident.ReplyTo != "" {
+				if _, err := mail.ParseAddress(ident.ReplyTo); err != nil {
+					addErrorf("%s: identity %q: parsing reply-to address %q: %v", descr, ident.Address, ident.ReplyTo, err)
+				}
+			}
+		}
+	}
+
+	checkDelegations := func(accName string, l []config.Delegation) {
+		seen := map[string]bool{}
+		for _, d := range l {
+			descr := fmt.Sprintf("account %s: delegation to account %q", accName, d.Account)
+			if _, ok := c.Accounts[d.Account]; !ok {
+				addErrorf("%s: account does not exist", descr)
+			} else if d.Account == accName {
+				addErrorf("%s: cannot delegate to own account", descr)
+			}
+			if seen[d.Account] {
+				addErrorf("%s: duplicate delegation", descr)
+			}
+			seen[d.Account] = true
+			if len(d.Mailboxes) == 0 {
+				addErrorf("%s: at least one mailbox required", descr)
+			}
+			for _, name := range d.Mailboxes {
+				if name == "" || strings.ContainsAny(name, "\r\n") {
+					addErrorf("%s: invalid mailbox name %q", descr, name)
+				}
+			}
+			switch d.Send {
+			case "", "as", "onbehalf":
+			default:
+				addErrorf("%s: unknown send %q, must be empty, as or onbehalf", descr, d.Send)
+			}
+		}
+	}
+
+	for i, rs := range c.RetrySchedules {
+		descr := fmt.Sprintf("retry schedule %d", i+1)
+		c.RetrySchedules[i].ToDomainASCII = parseRouteDomains(descr, rs.ToDomain)
+		switch rs.Class {
+		case "", "regular", "report":
+		default:
+			addErrorf("%s: unknown message class %q, must be empty, regular or report", descr, rs.Class)
+		}
+		for _, iv := range rs.Intervals {
+			if iv <= 0 {
+				addErrorf("%s: intervals must be positive", descr)
+				break
+			}
+		}
+		if rs.MinPriority < -9 || rs.MinPriority > 9 || rs.MaxPriority < -9 || rs.MaxPriority > 9 {
+			addErrorf("%s: priorities must be between -9 and 9", descr)
+		} else if rs.MinPriority != 0 && rs.MaxPriority != 0 && rs.MinPriority > rs.MaxPriority {
+			addErrorf("%s: min priority must be <= max priority", descr)
+		}
+		if rs.MaxAttempts < 0 {
+			addErrorf("%s: max attempts must be >= 0", descr)
+		}
+		if rs.MaxAge < 0 {
+			addErrorf("%s: max age must be >= 0", descr)
+		}
+	}
+
+	for i, tp := range c.TLSPolicies {
+		descr := fmt.Sprintf("tls policy %d", i+1)
+		if len(tp.ToDomain) == 0 {
+			addErrorf("%s: must have at least one domain", descr)
+		}
+		c.TLSPolicies[i].ToDomainASCII = parseRouteDomains(descr, tp.ToDomain)
+		switch tp.Mode {
+		case "", "verify", "required":
+		case "optional":
+			if len(tp.CertificateSHA256) > 0 {
+				addErrorf("%s: mode optional cannot be combined with certificate fingerprints", descr)
+			}
+		default:
+			addErrorf("%s: unknown mode %q, must be empty, verify, required or optional", descr, tp.Mode)
+		}
+		c.TLSPolicies[i].CertificateSHA256Parsed = nil
+		for _, fp := range tp.CertificateSHA256 {
+			buf, err := hex.DecodeString(strings.ReplaceAll(fp, ":", ""))
+			if err != nil || len(buf) != sha256.Size {
+				addErrorf("%s: invalid certificate sha-256 fingerprint %q, must be 32 bytes in hexadecimal", descr, fp)
+				continue
+			}
+			c.TLSPolicies[i].CertificateSHA256Parsed = append(c.TLSPolicies[i].CertificateSHA256Parsed, buf)
+		}
+	}
+
 	// Validate domains.
 	c.ClientSettingDomains = map[dns.Domain]struct{}{}
 	for d, domain := range c.Domains {
@@ -1269,6 +1905,58 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 				addDomainErrorf("unknown selector %s for signing", sign)
 			}
 		}
+		for i, rule := range domain.DKIM.SignRules {
+			addRuleErrorf := func(format string, args ...any) {
+				addDomainErrorf("dkim sign rule %d: %s", i+1, fmt.Sprintf(format, args...))
+			}
+
+			n := 0
+			if rule.MsgFromRegexp != "" {
+				n++
+				r, err := regexp.Compile(rule.MsgFromRegexp)
+				if err != nil {
+					addRuleErrorf("invalid MsgFrom regular expression: %v", err)
+				}
+				domain.DKIM.SignRules[i].MsgFromRegexpCompiled = r
+			}
+			var hdr [][2]*regexp.Regexp
+			for k, v := range rule.HeadersRegexp {
+				n++
+				if strings.ToLower(k) != k {
+					addRuleErrorf("header field %q must only have lower case characters", k)
+				}
+				if strings.ToLower(v) != v {
+					addRuleErrorf("header value %q must only have lower case characters", v)
+				}
+				rk, err := regexp.Compile(k)
+				if err != nil {
+					addRuleErrorf("invalid rule header regexp %q: %v", k, err)
+				}
+				rv, err := regexp.Compile(v)
+				if err != nil {
+					addRuleErrorf("invalid rule header regexp %q: %v", v, err)
+				}
+				hdr = append(hdr, [...]*regexp.Regexp{rk, rv})
+			}
+			domain.DKIM.SignRules[i].HeadersRegexpCompiled = hdr
+			if rule.Account != "" {
+				n++
+				if _, ok := c.Accounts[rule.Account]; !ok {
+					addRuleErrorf("unknown account %q", rule.Account)
+				}
+			}
+			if n == 0 {
+				addRuleErrorf("rule must have at least one match field")
+			}
+			if len(rule.Sign) == 0 {
+				addRuleErrorf("rule must have at least one selector to sign with")
+			}
+			for _, sign := range rule.Sign {
+				if _, ok := domain.DKIM.Selectors[sign]; !ok {
+					addRuleErrorf("unknown selector %s for signing", sign)
+				}
+			}
+		}
 		for name, sel := range domain.DKIM.Selectors {
 			addSelectorErrorf := func(format string, args ...any) {
 				addDomainErrorf("selector %s: %s", name, fmt.Sprintf(format, args...))
@@ -1377,7 +2065,120 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			}
 		}
 
+		if b := domain.BIMI; b != nil {
+			b.SelectorEffective = b.Selector
+			if b.SelectorEffective == "" {
+				b.SelectorEffective = "default"
+			} else if _, err := dns.ParseDomain(b.Selector); err != nil || strings.Contains(b.Selector, ".") {
+				addDomainErrorf("invalid bimi selector %q", b.Selector)
+			}
+			if (b.LogoFile == "") == (b.LogoURL == "") {
+				addDomainErrorf("bimi requires exactly one of LogoFile and LogoURL")
+			}
+			if b.AuthorityFile != "" && b.AuthorityURL != "" {
+				addDomainErrorf("bimi cannot have both AuthorityFile and AuthorityURL")
+			}
+			for _, u := range []string{b.LogoURL, b.AuthorityURL} {
+				if u == "" {
+					continue
+				}
+				if pu, err := url.Parse(u); err != nil || pu.Scheme != "https" || pu.Host == "" {
+					addDomainErrorf("bimi url %q must be an absolute https url", u)
+				}
+			}
+			if (b.LogoFile != "" || b.AuthorityFile != "") && !haveBIMIListener {
+				addDomainErrorf("bimi logo or authority file configured, but there is no listener for BIMIHTTPS")
+			}
+			if b.LogoFile != "" {
+				if buf, err := os.ReadFile(configDirPath(dynamicPath, b.LogoFile)); err != nil {
+					addDomainErrorf("reading bimi logo file: %v", err)
+				} else if len(buf) > 32*1024 {
+					addDomainErrorf("bimi logo file is %d bytes, must be at most 32KB", len(buf))
+				} else if !bytes.Contains(buf, []byte("<svg")) {
+					addDomainErrorf("bimi logo file does not look like an svg image")
+				}
+			}
+			if b.AuthorityFile != "" {
+				if _, err := os.Stat(configDirPath(dynamicPath, b.AuthorityFile)); err != nil {
+					addDomainErrorf("bimi authority file: %v", err)
+				}
+			}
+		}
+
+		if sa := domain.SpamActions; sa != nil {
+			if err := checkSpamActions(*sa); err != nil {
+				addDomainErrorf("spam actions: %v", err)
+			}
+		}
+
+		if sjf := domain.SharedJunkFilter; sjf != nil {
+			if sjf.Weight < 0 || sjf.Weight > 1 {
+				addDomainErrorf("shared junk filter Weight must be >= 0 and <= 1")
+			}
+			params := sjf.Params
+			if params.MaxPower < 0 || params.MaxPower > 0.5 {
+				addDomainErrorf("shared junk filter MaxPower must be >= 0 and < 0.5")
+			}
+			if params.TopWords < 0 {
+				addDomainErrorf("shared junk filter TopWords must be >= 0")
+			}
+			if params.IgnoreWords < 0 || params.IgnoreWords > 0.5 {
+				addDomainErrorf("shared junk filter IgnoreWords must be >= 0 and < 0.5")
+			}
+			if params.RareWords < 0 {
+				addDomainErrorf("shared junk filter RareWords must be >= 0")
+			}
+		}
+
+		if dp := domain.DNSProvisioning; dp != nil {
+			if _, ok := static.DNSProviders[dp.Provider]; !ok {
+				addDomainErrorf("unknown dns provider %q", dp.Provider)
+			}
+			dp.DNSZone = dnsdomain
+			if dp.Zone != "" {
+				dp.DNSZone, err = dns.ParseDomain(dp.Zone)
+				if err != nil {
+					addDomainErrorf("parsing dns zone: %v", err)
+				} else if dp.DNSZone != dnsdomain && !strings.HasSuffix(dnsdomain.ASCII, "."+dp.DNSZone.ASCII) {
+					addDomainErrorf("domain is not in dns zone %s", dp.DNSZone)
+				}
+			}
+			if dp.TTL < 0 {
+				addDomainErrorf("dns provisioning ttl must be >= 0")
+			}
+			if r := dp.DKIMRotation; r != nil && (r.Interval < 0 || r.PublishDelay < 0 || r.RetainPeriod < 0) {
+				addDomainErrorf("dkim rotation periods must be >= 0")
+			}
+		}
+		if r := domain.DKIM.Rotation; r != nil {
+			if r.Interval < 0 || r.PublishDelay < 0 || r.RetainPeriod < 0 {
+				addDomainErrorf("dkim rotation periods must be >= 0")
+			}
+			if dp := domain.DNSProvisioning; dp != nil && dp.DKIMRotation != nil {
+				addDomainErrorf("dkim rotation cannot be configured both in DKIM and DNSProvisioning")
+			}
+		}
+
+		if web := domain.Web; web != nil {
+			for _, color := range []string{web.Color, web.BackgroundColor} {
+				if color != "" && !webColorRegexp.MatchString(color) {
+					addDomainErrorf("invalid web color %q", color)
+				}
+			}
+			if w := web.WebmailDefaults; w != nil && w.UndoSendSeconds != 0 && (w.UndoSendSeconds < 5 || w.UndoSendSeconds > 30) {
+				addDomainErrorf("webmail default undo send seconds must be 0 or between 5 and 30")
+			}
+			for _, f := range web.DisabledFeatures {
+				if !slices.Contains(config.WebFeatures, f) {
+					addDomainErrorf("unknown web feature %q, valid values: %s", f, strings.Join(config.WebFeatures, ", "))
+				}
+			}
+		}
+
 		checkRoutes("routes for domain", domain.Routes)
+		checkOutgoingHeaderRules(fmt.Sprintf("outgoing header rules for domain %s", d), domain.OutgoingHeaderRules)
+		checkOutgoingFooter(fmt.Sprintf("outgoing footer for domain %s", d), domain.OutgoingFooter)
+		checkMessageTemplates(fmt.Sprintf("message templates for domain %s", d), domain.MessageTemplates)
 
 		c.Domains[d] = domain
 	}
@@ -1412,6 +2213,15 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			}
 		}
 
+		if pp := acc.PasswordPolicy; pp != nil {
+			if pp.MinLength < 0 || pp.MinEntropy < 0 || pp.MaxAge < 0 || pp.LockoutFailures < 0 || pp.LockoutPeriod < 0 {
+				addAccountErrorf("password policy values cannot be negative")
+			}
+			if pp.LockoutFailures == 0 && pp.LockoutPeriod != 0 {
+				addAccountErrorf("password policy LockoutPeriod requires LockoutFailures")
+			}
+		}
+
 		if acc.AutomaticJunkFlags.JunkMailboxRegexp != "" {
 			r, err := regexp.Compile(acc.AutomaticJunkFlags.JunkMailboxRegexp)
 			if err != nil {
@@ -1448,6 +2258,42 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			if params.RareWords < 0 {
 				addAccountErrorf("junk filter RareWords must be >= 0")
 			}
+			if err := checkSpamActions(acc.JunkFilter.SpamActions); err != nil {
+				addAccountErrorf("junk filter: %v", err)
+			}
+			if acc.JunkFilter.HighAction == config.SpamHighActionQuarantine && acc.RejectsMailbox == "" {
+				addAccountErrorf("junk filter HighAction quarantine requires a RejectsMailbox")
+			}
+		}
+
+		if acc.JunkReevaluation != nil && acc.JunkReevaluation.Window < 0 {
+			addAccountErrorf("junk reevaluation window must be >= 0")
+		}
+
+		if acc.Screening != nil && strings.EqualFold(acc.Screening.MailboxName(), "Inbox") {
+			addAccountErrorf("screening mailbox cannot be Inbox")
+		}
+
+		if acc.Categories != nil {
+			checkCategory := func(name string, cat *config.Category) {
+				if cat == nil {
+					return
+				}
+				if strings.EqualFold(cat.Mailbox, "Inbox") {
+					addAccountErrorf("%s category mailbox cannot be Inbox, leave empty to only set a keyword", name)
+				}
+				cat.DNSDomains = make([]dns.Domain, len(cat.Domains))
+				for i, s := range cat.Domains {
+					d, err := dns.ParseDomain(s)
+					if err != nil {
+						addAccountErrorf("%s category: parsing domain %q: %v", name, s, err)
+					}
+					cat.DNSDomains[i] = d
+				}
+			}
+			checkCategory("newsletters", acc.Categories.Newsletters)
+			checkCategory("notifications", acc.Categories.Notifications)
+			checkCategory("social", acc.Categories.Social)
 		}
 
 		acc.ParsedFromIDLoginAddresses = make([]smtp.Address, len(acc.FromIDLoginAddresses))
@@ -1468,6 +2314,7 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 
 		// Clear any previously derived state.
 		acc.Aliases = nil
+		acc.OwnedAliases = nil
 
 		c.Accounts[accName] = acc
 
@@ -1732,6 +2579,10 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 		}
 
 		checkRoutes("routes for account", acc.Routes)
+		checkOutgoingHeaderRules(fmt.Sprintf("outgoing header rules for account %s", accName), acc.OutgoingHeaderRules)
+		checkOutgoingFooter(fmt.Sprintf("outgoing footer for account %s", accName), acc.OutgoingFooter)
+		checkIdentities(fmt.Sprintf("identities for account %s", accName), acc.Identities)
+		checkDelegations(accName, acc.Delegations)
 	}
 
 	// Set DMARC destinations.
@@ -1783,6 +2634,9 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 			DMARCReports: true,
 		}
 		checkMailboxNormf(dmarc.Mailbox, "DMARC mailbox for account", addDomainErrorf)
+		if dmarc.FailureAlertPercentage < 0 || dmarc.FailureAlertPercentage > 100 {
+			addDomainErrorf("DMARC failure alert percentage must be between 0 and 100")
+		}
 		accDests[addrFull] = AccountDestination{false, lp, dmarc.Account, dest}
 	}
 
@@ -1907,13 +2761,58 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 					continue
 				}
 				seen[dastr] = true
-				aa := config.AliasAddress{Address: da, AccountName: accDest.Account, Destination: accDest.Destination}
+				aa := config.AliasAddress{Address: da, AccountName: accDest.Account, Destination: accDest.Destination, Member: a.Members[destAddr]}
 				a.ParsedAddresses = append(a.ParsedAddresses, aa)
 			}
+			for memberAddr, m := range a.Members {
+				if !slices.Contains(a.Addresses, memberAddr) {
+					addAliasErrorf("member preferences for address %q that is not a member", memberAddr)
+				}
+				checkMailboxNormf(m.Mailbox, fmt.Sprintf("member %s", memberAddr), addAliasErrorf)
+			}
+			switch a.PostPolicy {
+			case "", config.AliasPostMembers, config.AliasPostAnyone, config.AliasPostModerated:
+			default:
+				addAliasErrorf("unknown post policy %q, must be one of members, anyone or moderated", a.PostPolicy)
+			}
+			a.ParsedOwners = nil
+			for _, ownerAddr := range a.Owners {
+				oa, err := smtp.ParseAddress(ownerAddr)
+				if err != nil {
+					addAliasErrorf("parsing owner address %q: %v", ownerAddr, err)
+					continue
+				}
+				accDest, ok := accDests[oa.Pack(true)]
+				if !ok {
+					addAliasErrorf("owner references non-existent address %q", ownerAddr)
+					continue
+				}
+				a.ParsedOwners = append(a.ParsedOwners, config.AliasAddress{Address: oa, AccountName: accDest.Account, Destination: accDest.Destination})
+			}
+			if a.EffectivePostPolicy() == config.AliasPostModerated && len(a.ParsedOwners) == 0 {
+				addAliasErrorf("moderated alias needs at least one owner")
+			}
+			if a.List != nil {
+				// Email commands and bounce addresses use the catchall separator.
+				if len(domain.LocalpartCatchallSeparatorsEffective) == 0 {
+					addAliasErrorf("mailing list requires a localpart catchall separator for the domain")
+				}
+				if a.List.BounceLimit < 0 {
+					addAliasErrorf("bounce limit must be >= 0")
+				}
+			}
 			a.Domain = domain.Domain
 			c.Domains[d].Aliases[lpstr] = a
 			aliases[addr] = a
 
+			for _, oa := range a.ParsedOwners {
+				acc := c.Accounts[oa.AccountName]
+				if !slices.Contains(acc.OwnedAliases, addr) {
+					acc.OwnedAliases = append(acc.OwnedAliases, addr)
+				}
+				c.Accounts[oa.AccountName] = acc
+			}
+
 			for _, aa := range a.ParsedAddresses {
 				acc := c.Accounts[aa.AccountName]
 				var addrs []string
@@ -1928,15 +2827,60 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 					PostPublic:   a.PostPublic,
 					ListMembers:  a.ListMembers,
 					AllowMsgFrom: a.AllowMsgFrom,
+					PostPolicy:   a.PostPolicy,
+					ListHeaders:  a.ListHeaders,
 					LocalpartStr: a.LocalpartStr,
 					Domain:       a.Domain,
 				}
-				acc.Aliases = append(acc.Aliases, config.AddressAlias{SubscriptionAddress: aa.Address.Pack(true), Alias: accAlias, MemberAddresses: addrs})
+				acc.Aliases = append(acc.Aliases, config.AddressAlias{SubscriptionAddress: aa.Address.Pack(true), Alias: accAlias, MemberAddresses: addrs, Member: aa.Member})
 				c.Accounts[aa.AccountName] = acc
 			}
 		}
 	}
 
+	// Spamtraps, per domain. A domain with only spamtraps is not just for reports.
+	for d, domain := range c.Domains {
+		domain.SpamtrapLocalparts = nil
+		for _, lpstr := range domain.Spamtraps {
+			addSpamtrapErrorf := func(format string, args ...any) {
+				addErrorf("domain %s: spamtrap %s: %s", d, lpstr, fmt.Sprintf(format, args...))
+			}
+
+			lp, err := smtp.ParseLocalpart(lpstr)
+			if err != nil {
+				addSpamtrapErrorf("parsing localpart: %v", err)
+				continue
+			}
+			var hasSep bool
+			for _, sep := range domain.LocalpartCatchallSeparatorsEffective {
+				if strings.Contains(string(lp), sep) {
+					addSpamtrapErrorf("spamtrap contains localpart catchall separator")
+					hasSep = true
+				}
+			}
+			if hasSep {
+				continue
+			}
+			clp := CanonicalLocalpart(lp, domain)
+			addr := smtp.NewAddress(clp, domain.Domain).Pack(true)
+			if _, ok := accDests[addr]; ok {
+				addSpamtrapErrorf("spamtrap %q already present as regular address", addr)
+				continue
+			} else if _, ok := aliases[addr]; ok {
+				addSpamtrapErrorf("spamtrap %q already present as alias", addr)
+				continue
+			} else if slices.Contains(domain.SpamtrapLocalparts, clp) {
+				addSpamtrapErrorf("duplicate spamtrap %q", addr)
+				continue
+			}
+			domain.SpamtrapLocalparts = append(domain.SpamtrapLocalparts, clp)
+		}
+		if len(domain.SpamtrapLocalparts) > 0 {
+			domain.ReportsOnly = false
+		}
+		c.Domains[d] = domain
+	}
+
 	// Check webserver configs.
 	if (len(c.WebDomainRedirects) > 0 || len(c.WebHandlers) > 0) && !haveWebserverListener {
 		addErrorf("WebDomainRedirects or WebHandlers configured but no listener with WebserverHTTP or WebserverHTTPS enabled")
@@ -2098,9 +3042,50 @@ func prepareDynamicConfig(ctx context.Context, log mlog.Log, dynamicPath string,
 		c.MonitorDNSBLZones = append(c.MonitorDNSBLZones, d)
 	}
 
+	for name, au := range c.AdminUsers {
+		addAdminUserErrorf := func(format string, args ...any) {
+			addErrorf("admin user %q: %s", name, fmt.Sprintf(format, args...))
+		}
+		if !adminUserNameRegexp.MatchString(name) {
+			addAdminUserErrorf("invalid name, must consist of letters, digits, dot, dash and underscore")
+		}
+		switch au.Role {
+		case config.AdminRoleAdmin, config.AdminRoleAuditor:
+			if len(au.Domains) > 0 {
+				addAdminUserErrorf("domains only apply to role %s", config.AdminRoleDomainAdmin)
+			}
+		case config.AdminRoleDomainAdmin:
+			if len(au.Domains) == 0 {
+				addAdminUserErrorf("role %s requires domains", config.AdminRoleDomainAdmin)
+			}
+		default:
+			addAdminUserErrorf("unknown role %q", au.Role)
+		}
+		// Domains are not required to be configured, so domains can be removed without
+		// updating admin users first.
+		au.DNSDomains = nil
+		for _, s := range au.Domains {
+			d, err := dns.ParseDomain(s)
+			if err != nil {
+				addAdminUserErrorf("parsing domain %q: %v", s, err)
+				continue
+			}
+			au.DNSDomains = append(au.DNSDomains, d)
+		}
+		if !strings.HasPrefix(au.PasswordHash, "$2") {
+			addAdminUserErrorf("password hash must be a bcrypt hash")
+		}
+		c.AdminUsers[name] = au
+	}
+
 	return
 }
 
+var adminUserNameRegexp = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9_.-]*$`)
+
+// Colors for web interfaces, included in CSS, so no characters that can end a declaration.
+var webColorRegexp = regexp.MustCompile(`^(#[0-9a-fA-F]{3,8}|[a-zA-Z]+|(rgb|rgba|hsl|hsla)\([0-9a-z., %/]+\))$`)
+
 func loadPrivateKeyFile(keyPath string) (crypto.Signer, error) {
 	keyBuf, err := os.ReadFile(keyPath)
 	if err != nil {
@@ -2130,24 +3115,6 @@ func loadPrivateKeyFile(keyPath string) (crypto.Signer, error) {
 	return nil, fmt.Errorf("parsed private key not a crypto.Signer, but %T", privKey)
 }
 
-func loadTLSKeyCerts(configFile, kind string, ctls *config.TLS) error {
-	certs := []tls.Certificate{}
-	for _, kp := range ctls.KeyCerts {
-		certPath := configDirPath(configFile, kp.CertFile)
-		keyPath := configDirPath(configFile, kp.KeyFile)
-		cert, err := loadX509KeyPairPrivileged(certPath, keyPath)
-		if err != nil {
-			return fmt.Errorf("tls config for %q: parsing x509 key pair: %v", kind, err)
-		}
-		certs = append(certs, cert)
-	}
-	ctls.Config = &tls.Config{
-		Certificates: certs,
-	}
-	ctls.ConfigFallback = ctls.Config
-	return nil
-}
-
 // load x509 key/cert files from file descriptor possibly passed in by privileged
 // process.
 func loadX509KeyPairPrivileged(certPath, keyPath string) (tls.Certificate, error) {
@@ -2171,3 +3138,20 @@ func readFilePrivileged(path string) ([]byte, error) {
 	defer f.Close()
 	return io.ReadAll(f)
 }
+
+// checkSpamActions checks the thresholds and action of spam actions for a domain
+// or account.
+func checkSpamActions(sa config.SpamActions) error {
+	if sa.TagThreshold < 0 || sa.TagThreshold > 1 {
+		return fmt.Errorf("TagThreshold must be >= 0 and <= 1")
+	}
+	if sa.JunkThreshold < 0 || sa.JunkThreshold > 1 {
+		return fmt.Errorf("JunkThreshold must be >= 0 and <= 1")
+	}
+	switch sa.HighAction {
+	case "", config.SpamHighActionReject, config.SpamHighActionQuarantine:
+	default:
+		return fmt.Errorf("unknown HighAction %q, must be reject or quarantine", sa.HighAction)
+	}
+	return nil
+}
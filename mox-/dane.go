@@ -0,0 +1,89 @@
+package mox
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mjl-/adns"
+	"github.com/mjl-/autocert"
+
+	"github.com/mjl-/mox/autotls"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+)
+
+// DANERecord returns the DANE-EE TLSA record for a TLS public key, based on the
+// SHA2-256 hash of its SubjectPublicKeyInfo, as suggested for our own mail host.
+func DANERecord(pubKey crypto.PublicKey) (adns.TLSA, error) {
+	spkiBuf, err := x509.MarshalPKIXPublicKey(pubKey)
+	if err != nil {
+		return adns.TLSA{}, fmt.Errorf("marshal SubjectPublicKeyInfo for DANE record: %v", err)
+	}
+	sum := sha256.Sum256(spkiBuf)
+	return adns.TLSA{
+		Usage:     adns.TLSAUsageDANEEE,
+		Selector:  adns.TLSASelectorSPKI,
+		MatchType: adns.TLSAMatchTypeSHA256,
+		CertAssoc: sum[:],
+	}, nil
+}
+
+// daneRolloverKey is called before requesting a new certificate for host through
+// ACME with the configured host private key. If the key differs from the key of
+// the current certificate, the new certificate rolls over to a new key. If the
+// host has DNSSEC-signed TLSA records, but none matches the new key, remote
+// servers would fail to verify the new certificate with DANE. An error is logged,
+// and if block is set, the key of the current certificate is returned to be used
+// for the new certificate instead.
+func daneRolloverKey(log mlog.Log, resolver dns.Resolver, manager *autotls.Manager, host string, keyType autocert.KeyType, key crypto.Signer, block bool) crypto.Signer {
+	ctx, cancel := context.WithTimeout(Shutdown, 30*time.Second)
+	defer cancel()
+
+	hostDom := dns.Domain{ASCII: host}
+	curKey, _, err := manager.CachedKeyCert(ctx, hostDom, keyType == autocert.KeyRSA2048)
+	if err != nil {
+		log.Errorx("getting current certificate for checking dane key rollover", err, slog.String("host", host))
+		return key
+	} else if curKey == nil {
+		return key
+	} else if pk, ok := curKey.Public().(interface{ Equal(crypto.PublicKey) bool }); ok && pk.Equal(key.Public()) {
+		return key
+	}
+
+	record, err := DANERecord(key.Public())
+	if err != nil {
+		log.Errorx("making dane record for new key", err, slog.String("host", host))
+		return key
+	}
+
+	records, result, err := resolver.LookupTLSA(ctx, 25, "tcp", host+".")
+	if dns.IsNotFound(err) || err == nil && (!result.Authentic || len(records) == 0) {
+		// No DANE for this host, nothing to break.
+		return key
+	} else if err == nil && slices.ContainsFunc(records, func(r adns.TLSA) bool { return r.Record() == record.Record() }) {
+		log.Info("rolling over to new host private key for certificate, with published dane tlsa record", slog.String("host", host), slog.Any("keytype", keyType))
+		return key
+	}
+
+	if err != nil {
+		log.Errorx("looking up dane tlsa records for checking key rollover", err, slog.String("host", host))
+	}
+	if block {
+		log.Error("not rolling over to new host private key for certificate: no dane tlsa record for new key published, keeping key of current certificate; publish the tlsa record, wait for the ttl of the current records to expire, and renew the certificate",
+			slog.String("host", host),
+			slog.Any("keytype", keyType),
+			slog.String("tlsa", record.Record()))
+		return curKey
+	}
+	log.Error("rolling over to new host private key for certificate without published dane tlsa record for new key, remote servers verifying with dane will fail to deliver",
+		slog.String("host", host),
+		slog.Any("keytype", keyType),
+		slog.String("tlsa", record.Record()))
+	return key
+}
@@ -0,0 +1,99 @@
+package mox
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/adns"
+	"github.com/mjl-/autocert"
+
+	"github.com/mjl-/mox/autotls"
+	"github.com/mjl-/mox/dns"
+)
+
+func TestDANERolloverKey(t *testing.T) {
+	tcheck := func(err error, msg string) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %s", msg, err)
+		}
+	}
+
+	if Shutdown == nil {
+		Shutdown, ShutdownCancel = context.WithCancel(context.Background())
+	}
+
+	genKey := func() *ecdsa.PrivateKey {
+		k, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+		tcheck(err, "generate key")
+		return k
+	}
+	curKey, newKey := genKey(), genKey()
+
+	// Current certificate in the acme cache.
+	acmeDir := t.TempDir()
+	manager, err := autotls.Load(pkglog, "test", acmeDir, "mjl@mox.example", "https://localhost/directory", "", nil, nil, make(chan struct{}))
+	tcheck(err, "load autotls manager")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"mail.mox.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certBuf, err := x509.CreateCertificate(cryptorand.Reader, template, template, curKey.Public(), curKey)
+	tcheck(err, "create certificate")
+	keyBuf, err := x509.MarshalECPrivateKey(curKey)
+	tcheck(err, "marshal key")
+	data := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyBuf})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBuf})...)
+	err = os.MkdirAll(filepath.Join(acmeDir, "keycerts", "test"), 0700)
+	tcheck(err, "mkdir")
+	err = os.WriteFile(filepath.Join(acmeDir, "keycerts", "test", "mail.mox.example"), data, 0600)
+	tcheck(err, "write cached keycert")
+
+	record := func(k crypto.Signer) adns.TLSA {
+		r, err := DANERecord(k.Public())
+		tcheck(err, "dane record")
+		return r
+	}
+	resolver := dns.MockResolver{
+		TLSA:         map[string][]adns.TLSA{"_25._tcp.mail.mox.example.": {record(curKey)}},
+		AllAuthentic: true,
+	}
+
+	test := func(key crypto.Signer, block bool, expKey crypto.Signer) {
+		t.Helper()
+		k := daneRolloverKey(pkglog, resolver, manager, "mail.mox.example", autocert.KeyECDSAP256, key, block)
+		if !k.Public().(*ecdsa.PublicKey).Equal(expKey.Public()) {
+			t.Fatalf("got other key than expected")
+		}
+	}
+
+	// Same key as current certificate.
+	test(curKey, true, curKey)
+	// New key without published TLSA record, kept at current key if blocked.
+	test(newKey, false, newKey)
+	test(newKey, true, curKey)
+	// New key with published TLSA record.
+	resolver.TLSA["_25._tcp.mail.mox.example."] = []adns.TLSA{record(curKey), record(newKey)}
+	test(newKey, true, newKey)
+	// No DNSSEC, so no DANE to break.
+	resolver.TLSA["_25._tcp.mail.mox.example."] = []adns.TLSA{record(curKey)}
+	resolver.AllAuthentic = false
+	test(newKey, true, newKey)
+	// No certificate for host yet.
+	k := daneRolloverKey(pkglog, resolver, manager, "other.mox.example", autocert.KeyECDSAP256, newKey, true)
+	if k != crypto.Signer(newKey) {
+		t.Fatalf("got other key than expected")
+	}
+}
@@ -4,20 +4,78 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"net/textproto"
 	"strings"
 	"time"
 
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/smtp"
 )
 
 // DKIMSelectors returns the selectors to use for signing.
 func DKIMSelectors(dkimConf config.DKIM) []dkim.Selector {
+	return dkimSelectors(dkimConf, dkimConf.Sign)
+}
+
+// DKIMSelectorsMessage returns the selectors to sign a message with, based on
+// the first matching DKIM sign rule, falling back to the selectors in Sign.
+//
+// accountName is the account that submitted the message, empty for messages
+// generated by mox itself. msg is only read when a rule matches on headers.
+func DKIMSelectorsMessage(log mlog.Log, dkimConf config.DKIM, accountName string, msgFrom smtp.Address, msg io.ReaderAt) []dkim.Selector {
+	var header textproto.MIMEHeader
+	var headerParsed bool
+
+rule:
+	for _, rule := range dkimConf.SignRules {
+		if rule.Account != "" && rule.Account != accountName {
+			continue
+		}
+		if rule.MsgFromRegexpCompiled != nil && !rule.MsgFromRegexpCompiled.MatchString(msgFrom.String()) {
+			continue
+		}
+
+		if len(rule.HeadersRegexpCompiled) > 0 && !headerParsed {
+			headerParsed = true
+			p, err := message.Parse(log.Logger, false, msg)
+			if err != nil {
+				log.Debugx("parsing message for evaluating dkim sign rules, continuing with headers", err)
+			}
+			header, err = p.Header()
+			if err != nil {
+				log.Errorx("parsing message headers for evaluating dkim sign rules", err)
+			}
+		}
+	header:
+		for _, t := range rule.HeadersRegexpCompiled {
+			for k, vl := range header {
+				k = strings.ToLower(k)
+				if !t[0].MatchString(k) {
+					continue
+				}
+				for _, v := range vl {
+					v = strings.ToLower(strings.TrimSpace(v))
+					if t[1].MatchString(v) {
+						continue header
+					}
+				}
+			}
+			continue rule
+		}
+
+		return dkimSelectors(dkimConf, rule.Sign)
+	}
+	return dkimSelectors(dkimConf, dkimConf.Sign)
+}
+
+func dkimSelectors(dkimConf config.DKIM, names []string) []dkim.Selector {
 	var l []dkim.Selector
-	for _, sign := range dkimConf.Sign {
+	for _, sign := range names {
 		sel := dkimConf.Selectors[sign]
 		s := dkim.Selector{
 			Hash:          sel.HashEffective,
@@ -58,7 +116,7 @@ func DKIMSign(ctx context.Context, log mlog.Log, from smtp.Path, smtputf8 bool,
 			return "", ErrDomainDisabled
 		}
 
-		selectors := DKIMSelectors(confDom.DKIM)
+		selectors := DKIMSelectorsMessage(log, confDom.DKIM, "", smtp.NewAddress(from.Localpart, from.IPDomain.Domain), bytes.NewReader(data))
 		dkimHeaders, err := dkim.Sign(ctx, log.Logger, from.Localpart, fd, selectors, smtputf8, bytes.NewReader(data))
 		if err != nil {
 			return "", fmt.Errorf("dkim sign for domain %s: %v", fd, err)
@@ -0,0 +1,65 @@
+package mox
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestDKIMSelectorsMessage(t *testing.T) {
+	log := mlog.New("mox", nil)
+
+	sel := func(name string) config.Selector {
+		return config.Selector{HashEffective: "sha256", Domain: dns.Domain{ASCII: name}}
+	}
+	dkimConf := config.DKIM{
+		Selectors: map[string]config.Selector{
+			"default": sel("default"),
+			"rsa":     sel("rsa"),
+			"ed":      sel("ed"),
+			"list":    sel("list"),
+		},
+		Sign: []string{"default"},
+		SignRules: []config.DKIMSignRule{
+			{
+				MsgFromRegexp:         "^news@",
+				MsgFromRegexpCompiled: regexp.MustCompile("^news@"),
+				Sign:                  []string{"rsa", "ed"},
+			},
+			{
+				Account:               "mjl",
+				HeadersRegexpCompiled: [][2]*regexp.Regexp{{regexp.MustCompile("^list-id$"), regexp.MustCompile("^<test\\.example>$")}},
+				Sign:                  []string{"list"},
+			},
+		},
+	}
+
+	test := func(account, from, msg string, exp ...string) {
+		t.Helper()
+		addr, err := smtp.ParseAddress(from)
+		if err != nil {
+			t.Fatalf("parse address: %v", err)
+		}
+		var names []string
+		for _, s := range DKIMSelectorsMessage(log, dkimConf, account, addr, strings.NewReader(msg)) {
+			names = append(names, s.Domain.ASCII)
+		}
+		if strings.Join(names, ",") != strings.Join(exp, ",") {
+			t.Fatalf("got selectors %v, expected %v", names, exp)
+		}
+	}
+
+	const listMsg = "From: <mjl@mox.example>\r\nList-Id:  <test.example> \r\n\r\nbody\r\n"
+	const plainMsg = "From: <mjl@mox.example>\r\n\r\nbody\r\n"
+
+	test("other", "news@mox.example", plainMsg, "rsa", "ed")
+	test("", "news@mox.example", plainMsg, "rsa", "ed")
+	test("mjl", "mjl@mox.example", listMsg, "list")
+	test("other", "mjl@mox.example", listMsg, "default")
+	test("mjl", "mjl@mox.example", plainMsg, "default")
+}
@@ -0,0 +1,41 @@
+package mox
+
+import (
+	"net"
+	"slices"
+)
+
+// Exempt holds the checks that are exempted for a connection or submission, by
+// the Exemptions in the configuration.
+type Exempt struct {
+	JunkFilter bool
+	DNSBL      bool
+	Delays     bool
+	RateLimits bool
+
+	Comments []string // Of the matching exemptions, for logging.
+}
+
+// Exempted returns the checks that are exempted for an SMTP connection from ip, or
+// for an authenticated submission by account. Either ip or account can be empty.
+// Only rate limits are exempted for submissions.
+func Exempted(ip net.IP, account string) (e Exempt) {
+	for _, x := range Conf.Static.Exemptions {
+		var match bool
+		if ip != nil && slices.ContainsFunc(x.IPNets, func(n net.IPNet) bool { return n.Contains(ip) }) {
+			e.JunkFilter = e.JunkFilter || x.JunkFilter
+			e.DNSBL = e.DNSBL || x.DNSBL
+			e.Delays = e.Delays || x.Delays
+			e.RateLimits = e.RateLimits || x.RateLimits
+			match = true
+		}
+		if account != "" && x.RateLimits && (slices.Contains(x.Accounts, account) || slices.Contains(x.Accounts, "*")) {
+			e.RateLimits = true
+			match = true
+		}
+		if match {
+			e.Comments = append(e.Comments, x.Comment)
+		}
+	}
+	return
+}
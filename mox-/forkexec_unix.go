@@ -53,11 +53,12 @@ func ForkExecUnprivileged() {
 	CleanupPassedFiles()
 
 	// If we get a interrupt/terminate signal, pass it on to the child. For interrupt,
-	// the child probably already got it.
+	// the child probably already got it. Hangup, for reloading TLS certificates, is
+	// passed on as well.
 	// todo: see if we tie up child and root process so a kill -9 of the root process
 	// kills the child process too.
 	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
 		for {
 			sig := <-sigc
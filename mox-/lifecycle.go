@@ -7,6 +7,7 @@ import (
 	"os"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -166,9 +167,10 @@ var ContextCancel func()
 // which the connections get 1 more second for error handling before actual
 // shutdown.
 var Connections = &connections{
-	conns:  map[net.Conn]connKind{},
-	gauges: map[connKind]prometheus.GaugeFunc{},
-	active: map[connKind]int64{},
+	conns:    map[net.Conn]connKind{},
+	gauges:   map[connKind]prometheus.GaugeFunc{},
+	active:   map[connKind]int64{},
+	sessions: map[net.Conn]*accountSession{},
 }
 
 type connKind struct {
@@ -184,6 +186,29 @@ type connections struct {
 
 	activeMutex sync.Mutex
 	active      map[connKind]int64
+
+	// Authenticated connections, for listing and closing sessions of an account.
+	sessions      map[net.Conn]*accountSession
+	lastSessionID int64
+}
+
+type accountSession struct {
+	accountName string
+	closed      bool // Once closed, activity is no longer recorded.
+	ConnSession
+}
+
+// ConnSession is an authenticated protocol connection of an account, e.g. an IMAP
+// connection of a mail client.
+type ConnSession struct {
+	ID           int64  // Unique while mox is running.
+	Protocol     string // E.g. "imap" or "submission".
+	Listener     string
+	RemoteIP     string
+	LoginAddress string
+	UserAgent    string    // If known, e.g. from IMAP ID command.
+	Started      time.Time // Of authentication.
+	LastActivity time.Time
 }
 
 // Register adds a connection for receiving an immediate i/o deadline on shutdown.
@@ -239,6 +264,7 @@ func (c *connections) Unregister(nc net.Conn) {
 	}()
 
 	delete(c.conns, nc)
+	delete(c.sessions, nc)
 	if len(c.conns) > 0 {
 		return
 	}
@@ -248,6 +274,71 @@ func (c *connections) Unregister(nc net.Conn) {
 	c.dones = nil
 }
 
+// Activity records activity on a connection that has authenticated for an
+// account, for listing active sessions of the account. The first call starts the
+// session. The connection must be registered.
+func (c *connections) Activity(nc net.Conn, protocol, accountName, loginAddress, userAgent string) {
+	now := time.Now()
+
+	c.Lock()
+	defer c.Unlock()
+	ck, ok := c.conns[nc]
+	if !ok {
+		return
+	}
+	as := c.sessions[nc]
+	if as != nil && as.closed {
+		return
+	} else if as == nil || as.accountName != accountName {
+		remoteIP, _, _ := net.SplitHostPort(nc.RemoteAddr().String())
+		c.lastSessionID++
+		as = &accountSession{accountName, false, ConnSession{ID: c.lastSessionID, Protocol: protocol, Listener: ck.listener, RemoteIP: remoteIP, Started: now}}
+		c.sessions[nc] = as
+	}
+	as.LoginAddress = loginAddress
+	as.UserAgent = userAgent
+	as.LastActivity = now
+}
+
+// AccountSessions returns the authenticated connections of an account, most
+// recently active first.
+func (c *connections) AccountSessions(accountName string) []ConnSession {
+	c.Lock()
+	defer c.Unlock()
+	l := []ConnSession{}
+	for _, as := range c.sessions {
+		if as.accountName == accountName && !as.closed {
+			l = append(l, as.ConnSession)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].LastActivity.After(l[j].LastActivity)
+	})
+	return l
+}
+
+// AccountSessionsClose sets an immediate i/o deadline on the authenticated
+// connections of an account, causing them to be closed. If id is non-zero, only
+// the connection with that session ID is closed. The number of connections closed
+// is returned.
+func (c *connections) AccountSessionsClose(accountName string, id int64) int {
+	now := time.Now()
+	c.Lock()
+	defer c.Unlock()
+	var n int
+	for nc, as := range c.sessions {
+		if as.accountName != accountName || as.closed || id != 0 && as.ID != id {
+			continue
+		}
+		if err := nc.SetDeadline(now); err != nil {
+			pkglog.Errorx("setting immediate read/write deadline for closing session", err)
+		}
+		as.closed = true
+		n++
+	}
+	return n
+}
+
 // Shutdown sets an immediate i/o deadline on all open registered sockets. Called
 // some time after mox shutdown is initiated.
 // The deadline will cause i/o's to be aborted, which should result in the
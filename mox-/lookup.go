@@ -2,6 +2,7 @@ package mox
 
 import (
 	"errors"
+	"slices"
 	"strings"
 
 	"github.com/mjl-/mox/config"
@@ -131,6 +132,16 @@ func CanonicalLocalpart(localpart smtp.Localpart, d config.Domain) smtp.Localpar
 	return localpart
 }
 
+// IsSpamtrap returns whether the address is a spamtrap of an enabled domain, as
+// configured with Spamtraps in the domain.
+func IsSpamtrap(localpart smtp.Localpart, domain dns.Domain) bool {
+	d, ok := Conf.Domain(domain)
+	if !ok || d.Disabled || len(d.SpamtrapLocalparts) == 0 {
+		return false
+	}
+	return slices.Contains(d.SpamtrapLocalparts, CanonicalLocalpart(localpart, d))
+}
+
 // AllowMsgFrom returns whether account is allowed to submit messages with address
 // as message From header, based on configured addresses and membership of aliases
 // that allow using its address.
@@ -149,3 +160,34 @@ func AllowMsgFrom(accountName string, msgFrom smtp.Address) (ok, domainDisabled
 	}
 	return accName == accountName, false
 }
+
+// AccountDelegation returns the delegation from account owner to account
+// delegate, if any.
+func AccountDelegation(owner, delegate string) (config.Delegation, bool) {
+	accConf, ok := Conf.Account(owner)
+	if !ok {
+		return config.Delegation{}, false
+	}
+	for _, d := range accConf.Delegations {
+		if d.Account == delegate {
+			return d, true
+		}
+	}
+	return config.Delegation{}, false
+}
+
+// AllowDelegatedMsgFrom returns whether account is allowed to submit messages with
+// address as message From header because the account of the address delegated
+// sending to account. If onBehalf is set, the message must have a Sender header
+// with an address of account.
+func AllowDelegatedMsgFrom(accountName string, msgFrom smtp.Address) (ok, onBehalf bool) {
+	owner, alias, _, _, err := LookupAddress(msgFrom.Localpart, msgFrom.Domain, false, false, true)
+	if err != nil || alias != nil {
+		return false, false
+	}
+	d, ok := AccountDelegation(owner, accountName)
+	if !ok || d.Send == "" {
+		return false, false
+	}
+	return true, d.Send == "onbehalf"
+}
@@ -0,0 +1,65 @@
+package mox
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"syscall"
+	"time"
+)
+
+// ErrAddressNotAllowed is returned when connecting to a non-public IP address
+// with a client from PublicHTTPClient.
+var ErrAddressNotAllowed = errors.New("remote address not allowed")
+
+// PublicAllowIP returns whether a client from PublicHTTPClient may connect to
+// ip. Only public addresses are allowed. Replaced by tests.
+var PublicAllowIP = func(ip net.IP) bool {
+	return ip.IsGlobalUnicast() && !ip.IsPrivate()
+}
+
+// PublicHTTPClient returns an HTTP client for requests to URLs from untrusted
+// sources, such as DNS records of remote domains or users. It only connects to
+// public IP addresses, so it cannot be used to reach internal services.
+//
+// If redirectSchemes is empty, redirects are not followed, the response with the
+// redirect is returned instead. Otherwise, a limited number of redirects to URLs
+// with one of redirectSchemes, e.g. "https", are followed.
+func PublicHTTPClient(timeout time.Duration, redirectSchemes ...string) *http.Client {
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout: 10 * time.Second,
+				// Checked at connect time, after name resolution, so DNS cannot be used to
+				// point to internal addresses.
+				Control: func(network, address string, c syscall.RawConn) error {
+					host, _, err := net.SplitHostPort(address)
+					if err != nil {
+						return err
+					}
+					if ip := net.ParseIP(host); ip == nil || !PublicAllowIP(ip) {
+						return fmt.Errorf("%w: %s", ErrAddressNotAllowed, host)
+					}
+					return nil
+				},
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 20 * time.Second,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(redirectSchemes) == 0 {
+				return http.ErrUseLastResponse
+			} else if len(via) >= 5 {
+				return errors.New("too many redirects")
+			} else if !slices.Contains(redirectSchemes, req.URL.Scheme) {
+				return fmt.Errorf("redirect to url with unsupported scheme %q", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,283 @@
+package mox
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+)
+
+// tlsCerts holds the certificates of a listener with static keys/certificates
+// and/or certificate sources. The certificates can be reloaded while running.
+type tlsCerts struct {
+	configFile string
+	ctls       *config.TLS
+	keyCerts   []tls.Certificate // From KeyCerts at startup, possibly opened by the privileged root process.
+	certs      atomic.Pointer[[]tls.Certificate]
+}
+
+var (
+	tlsCertsLock sync.Mutex
+	tlsCertsList = map[*config.TLS]*tlsCerts{}
+)
+
+var vaultHTTPClient = &http.Client{Timeout: time.Minute}
+
+func loadTLSKeyCerts(configFile, kind string, ctls *config.TLS) error {
+	tc := &tlsCerts{configFile: configFile, ctls: ctls}
+	for _, kp := range ctls.KeyCerts {
+		certPath := configDirPath(configFile, kp.CertFile)
+		keyPath := configDirPath(configFile, kp.KeyFile)
+		cert, err := loadX509KeyPairPrivileged(certPath, keyPath)
+		if err != nil {
+			return fmt.Errorf("tls config for %q: parsing x509 key pair: %v", kind, err)
+		}
+		tc.keyCerts = append(tc.keyCerts, cert)
+	}
+	for _, src := range ctls.CertSources {
+		if v := src.Vault; v != nil {
+			buf, err := readFilePrivileged(configDirPath(configFile, v.TokenFile))
+			if err != nil {
+				return fmt.Errorf("tls config for %q: reading vault token: %v", kind, err)
+			}
+			v.Token = strings.TrimSpace(string(buf))
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	certs, err := tc.load(ctx, pkglog, false)
+	if err != nil {
+		return fmt.Errorf("tls config for %q: %v", kind, err)
+	}
+	tc.certs.Store(&certs)
+
+	ctls.Config = &tls.Config{
+		GetCertificate: tc.getCertificate,
+	}
+	ctls.ConfigFallback = ctls.Config
+
+	tlsCertsLock.Lock()
+	defer tlsCertsLock.Unlock()
+	tlsCertsList[ctls] = tc
+	return nil
+}
+
+// getCertificate returns the first certificate matching the requested hostname,
+// or the first certificate, like crypto/tls does with a list of certificates.
+func (tc *tlsCerts) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	certs := *tc.certs.Load()
+	for i := range certs {
+		if hello.SupportsCertificate(&certs[i]) == nil {
+			return &certs[i], nil
+		}
+	}
+	return &certs[0], nil
+}
+
+// load returns the certificates from KeyCerts and the certificate sources. When
+// reloading, KeyCerts files are read again directly, keeping the certificate
+// loaded at startup if the files cannot be read due to permissions.
+func (tc *tlsCerts) load(ctx context.Context, log mlog.Log, reload bool) ([]tls.Certificate, error) {
+	certs := slices.Clone(tc.keyCerts)
+	if reload {
+		for i, kp := range tc.ctls.KeyCerts {
+			certPath := configDirPath(tc.configFile, kp.CertFile)
+			keyPath := configDirPath(tc.configFile, kp.KeyFile)
+			cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+			if err != nil && errors.Is(err, fs.ErrPermission) {
+				log.Debugx("cannot read key and certificate files for reload, keeping certificate loaded at startup", err, slog.String("certfile", certPath))
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("parsing x509 key pair: %v", err)
+			}
+			certs[i] = cert
+		}
+	}
+
+	for i, src := range tc.ctls.CertSources {
+		var l []tls.Certificate
+		var err error
+		if src.Directory != "" {
+			l, err = tlsCertsDirectory(configDirPath(tc.configFile, src.Directory))
+		} else if src.Vault != nil {
+			l, err = tlsCertsVault(ctx, *src.Vault)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("certificate source %d: %w", i, err)
+		}
+		certs = append(certs, l...)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates")
+	}
+
+	for i := range certs {
+		if certs[i].Leaf == nil {
+			leaf, err := x509.ParseCertificate(certs[i].Certificate[0])
+			if err != nil {
+				return nil, fmt.Errorf("parsing certificate: %v", err)
+			}
+			certs[i].Leaf = leaf
+		}
+	}
+	return certs, nil
+}
+
+// tlsCertsDirectory reads keys and certificates from pairs of files <name>.crt
+// and <name>.key (e.g. lego), and from subdirectories with fullchain.pem and
+// privkey.pem (e.g. certbot).
+func tlsCertsDirectory(dir string) ([]tls.Certificate, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	exists := func(p string) bool {
+		_, err := os.Stat(p)
+		return err == nil
+	}
+	var certs []tls.Certificate
+	for _, e := range entries {
+		var certPath, keyPath string
+		p := filepath.Join(dir, e.Name())
+		if e.IsDir() {
+			certPath = filepath.Join(p, "fullchain.pem")
+			keyPath = filepath.Join(p, "privkey.pem")
+		} else if name, ok := strings.CutSuffix(e.Name(), ".crt"); ok {
+			certPath = p
+			keyPath = filepath.Join(dir, name+".key")
+		} else {
+			continue
+		}
+		// E.g. lego also writes an <name>.issuer.crt, without key.
+		if !exists(certPath) || !exists(keyPath) {
+			continue
+		}
+		cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+		if err != nil {
+			return nil, fmt.Errorf("parsing x509 key pair %s: %v", certPath, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// tlsCertsVault fetches keys and certificates from key/value secrets in Vault.
+func tlsCertsVault(ctx context.Context, v config.CertSourceVault) ([]tls.Certificate, error) {
+	certField := v.CertificateField
+	if certField == "" {
+		certField = "certificate"
+	}
+	keyField := v.PrivateKeyField
+	if keyField == "" {
+		keyField = "private_key"
+	}
+
+	var certs []tls.Certificate
+	for _, p := range v.Paths {
+		u := strings.TrimSuffix(v.Address, "/") + "/v1/" + strings.TrimPrefix(p, "/")
+		req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+		if err != nil {
+			return nil, fmt.Errorf("new request: %v", err)
+		}
+		req.Header.Set("X-Vault-Token", v.Token)
+		resp, err := vaultHTTPClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("vault request for %s: %v", p, err)
+		}
+		var result struct {
+			Data map[string]any `json:"data"`
+		}
+		err = json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&result)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("vault request for %s: status %s", p, resp.Status)
+		} else if err != nil {
+			return nil, fmt.Errorf("parsing vault response for %s: %v", p, err)
+		}
+
+		// Version 2 key/value engines have the secret in a nested data field, next to
+		// metadata.
+		data := result.Data
+		if inner, ok := data["data"].(map[string]any); ok && data["metadata"] != nil {
+			data = inner
+		}
+		certPEM, _ := data[certField].(string)
+		keyPEM, _ := data[keyField].(string)
+		if certPEM == "" || keyPEM == "" {
+			return nil, fmt.Errorf("vault secret %s: missing field %q or %q", p, certField, keyField)
+		}
+		cert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("vault secret %s: parsing x509 key pair: %v", p, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// ReloadTLSCerts reloads the certificates of listeners with static
+// keys/certificates or certificate sources. Listeners for which loading fails
+// keep their current certificates. Names of listeners with reloaded certificates
+// are returned.
+func ReloadTLSCerts(ctx context.Context, log mlog.Log) (reloaded []string, rerr error) {
+	names := make([]string, 0, len(Conf.Static.Listeners))
+	for name := range Conf.Static.Listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		l := Conf.Static.Listeners[name]
+		if l.TLS == nil {
+			continue
+		}
+		tlsCertsLock.Lock()
+		tc := tlsCertsList[l.TLS]
+		tlsCertsLock.Unlock()
+		if tc == nil {
+			continue
+		}
+
+		certs, err := tc.load(ctx, log, true)
+		if err != nil {
+			log.Errorx("reloading tls certificates, keeping current certificates", err, slog.String("listener", name))
+			errs = append(errs, fmt.Errorf("listener %s: %w", name, err))
+			continue
+		}
+		tc.certs.Store(&certs)
+		reloaded = append(reloaded, name)
+		log.Info("tls certificates reloaded", slog.String("listener", name), slog.Int("certificates", len(certs)))
+	}
+	return reloaded, errors.Join(errs...)
+}
+
+// TLSCertificates returns the currently loaded certificates of a listener with
+// static keys/certificates or certificate sources, or nil for other listeners.
+func TLSCertificates(ctls *config.TLS) []tls.Certificate {
+	tlsCertsLock.Lock()
+	tc := tlsCertsList[ctls]
+	tlsCertsLock.Unlock()
+	if tc == nil {
+		return nil
+	}
+	return *tc.certs.Load()
+}
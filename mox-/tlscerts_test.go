@@ -0,0 +1,147 @@
+package mox
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+)
+
+func TestTLSCerts(t *testing.T) {
+	tcheck := func(err error, msg string) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s: %s", msg, err)
+		}
+	}
+
+	// Returns PEM-encoded self-signed certificate and private key for name.
+	serial := int64(1)
+	makeCert := func(name string) (certPEM, keyPEM []byte) {
+		t.Helper()
+		key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+		tcheck(err, "generate key")
+		serial++
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			DNSNames:     []string{name},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		der, err := x509.CreateCertificate(cryptorand.Reader, tmpl, tmpl, key.Public(), key)
+		tcheck(err, "create certificate")
+		keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+		tcheck(err, "marshal key")
+		return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	}
+	writeFile := func(p string, buf []byte) {
+		t.Helper()
+		err := os.MkdirAll(filepath.Dir(p), 0700)
+		tcheck(err, "mkdir")
+		err = os.WriteFile(p, buf, 0600)
+		tcheck(err, "write file")
+	}
+
+	dir := t.TempDir()
+	certDir := filepath.Join(dir, "certs")
+
+	// Layout as written by lego, with an issuer certificate without key that is
+	// ignored.
+	certPEM, keyPEM := makeCert("a.mox.example")
+	writeFile(filepath.Join(certDir, "a.mox.example.crt"), certPEM)
+	writeFile(filepath.Join(certDir, "a.mox.example.key"), keyPEM)
+	writeFile(filepath.Join(certDir, "a.mox.example.issuer.crt"), certPEM)
+
+	// Layout as written by certbot.
+	certPEM, keyPEM = makeCert("b.mox.example")
+	writeFile(filepath.Join(certDir, "b.mox.example", "fullchain.pem"), certPEM)
+	writeFile(filepath.Join(certDir, "b.mox.example", "privkey.pem"), keyPEM)
+
+	// Fake Vault with a secret in a version 2 key/value engine.
+	certPEM, keyPEM = makeCert("c.mox.example")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		} else if r.URL.Path != "/v1/secret/data/mox" {
+			http.NotFound(w, r)
+			return
+		}
+		data := map[string]any{"certificate": string(certPEM), "private_key": string(keyPEM)}
+		json.NewEncoder(w).Encode(map[string]any{"data": map[string]any{"data": data, "metadata": map[string]any{}}})
+	}))
+	defer srv.Close()
+	writeFile(filepath.Join(dir, "vaulttoken"), []byte("secret\n"))
+
+	FilesImmediate = true
+	defer func() {
+		FilesImmediate = false
+	}()
+	configFile := filepath.Join(dir, "mox.conf")
+	ctls := &config.TLS{
+		CertSources: []config.CertSource{
+			{Directory: "certs"},
+			{Vault: &config.CertSourceVault{Address: srv.URL, TokenFile: "vaulttoken", Paths: []string{"secret/data/mox"}}},
+		},
+	}
+	err := loadTLSKeyCerts(configFile, "listener test", ctls)
+	tcheck(err, "load tls certificates")
+
+	getCert := func(name string) []string {
+		t.Helper()
+		cert, err := ctls.Config.GetCertificate(&tls.ClientHelloInfo{ServerName: name, SupportedVersions: []uint16{tls.VersionTLS13}})
+		tcheck(err, "get certificate")
+		return cert.Leaf.DNSNames
+	}
+	for _, name := range []string{"a.mox.example", "b.mox.example", "c.mox.example"} {
+		if names := getCert(name); !slices.Equal(names, []string{name}) {
+			t.Fatalf("certificate for %s is for %v", name, names)
+		}
+	}
+	// Unknown names get the first certificate.
+	if names := getCert("other.mox.example"); !slices.Equal(names, []string{"a.mox.example"}) {
+		t.Fatalf("certificate for unknown name is for %v, expected first certificate", names)
+	}
+
+	static := Conf.Static
+	defer func() {
+		Conf.Static = static
+	}()
+	Conf.Static.Listeners = map[string]config.Listener{"test": {TLS: ctls}}
+
+	// New certificate is picked up after a reload.
+	certPEM, keyPEM = makeCert("d.mox.example")
+	writeFile(filepath.Join(certDir, "d.mox.example.crt"), certPEM)
+	writeFile(filepath.Join(certDir, "d.mox.example.key"), keyPEM)
+	reloaded, err := ReloadTLSCerts(context.Background(), pkglog)
+	tcheck(err, "reload")
+	if !slices.Equal(reloaded, []string{"test"}) {
+		t.Fatalf("reloaded %v, expected test", reloaded)
+	}
+	if names := getCert("d.mox.example"); !slices.Equal(names, []string{"d.mox.example"}) {
+		t.Fatalf("certificate after reload is for %v", names)
+	}
+
+	// Failed reload keeps current certificates.
+	writeFile(filepath.Join(certDir, "d.mox.example.key"), []byte("bogus"))
+	if _, err := ReloadTLSCerts(context.Background(), pkglog); err == nil {
+		t.Fatalf("reload with bad key succeeded")
+	}
+	if names := getCert("d.mox.example"); !slices.Equal(names, []string{"d.mox.example"}) {
+		t.Fatalf("certificate after failed reload is for %v", names)
+	}
+}
@@ -0,0 +1,465 @@
+// Package oidc implements the relying party side of OpenID Connect, for
+// delegating authentication to an identity provider.
+//
+// The web interfaces log users in with the authorization code flow, with PKCE.
+// IMAP and SMTP submission accept OAuth 2.0 bearer tokens, sent with SASL
+// mechanisms OAUTHBEARER (RFC 7628) and XOAUTH2. Tokens are verified with token
+// introspection (RFC 7662) at the identity provider, and must be issued for mox as
+// audience. In both cases, the user is identified by an email address verified by
+// the identity provider, which must belong to an account.
+//
+// The identity provider is configured in mox.conf, its endpoints are discovered
+// through OpenID Connect Discovery.
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/stub"
+)
+
+var (
+	HTTPClientObserve func(ctx context.Context, log *slog.Logger, pkg, method string, statusCode int, err error, start time.Time) = stub.HTTPClientObserveIgnore
+)
+
+// HTTPClient is used for requests to the identity provider.
+var HTTPClient = &http.Client{Timeout: 30 * time.Second}
+
+var (
+	ErrNotConfigured = errors.New("oidc: no identity provider configured")
+	ErrInvalidToken  = errors.New("oidc: invalid token")
+)
+
+// How long verified access tokens are remembered, preventing a request to the
+// identity provider for each IMAP/SMTP connection.
+const tokenCacheDuration = 5 * time.Minute
+
+// Provider holds the endpoints of an identity provider, from its discovery
+// document.
+type Provider struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+
+	// Optional, RFC 8414. Required for verifying access tokens.
+	IntrospectionEndpoint string `json:"introspection_endpoint,omitempty"`
+}
+
+var providerCache struct {
+	sync.Mutex
+	issuer   string
+	provider Provider
+	fetched  time.Time
+}
+
+var tokenCache = struct {
+	sync.Mutex
+	m map[[32]byte]cachedToken
+}{m: map[[32]byte]cachedToken{}}
+
+type cachedToken struct {
+	address string
+	expires time.Time
+}
+
+// Enabled returns whether an identity provider is configured.
+func Enabled() bool {
+	return mox.Conf.Static.OIDC != nil
+}
+
+func xconfig() (config.OIDC, error) {
+	if c := mox.Conf.Static.OIDC; c != nil {
+		return *c, nil
+	}
+	return config.OIDC{}, ErrNotConfigured
+}
+
+// request does an HTTP request to the identity provider, parsing a JSON response
+// into v. The HTTP status code is returned, also for unsuccessful responses, for
+// which v is not parsed.
+func request(ctx context.Context, log mlog.Log, req *http.Request, v any) (int, error) {
+	start := time.Now()
+	req.Header.Set("Accept", "application/json")
+	resp, err := HTTPClient.Do(req)
+	if resp == nil {
+		resp = &http.Response{StatusCode: 0}
+	}
+	HTTPClientObserve(ctx, log.Logger, "oidc", req.Method, resp.StatusCode, err, start)
+	if err != nil {
+		return 0, fmt.Errorf("http request: %v", err)
+	}
+	defer func() {
+		err := resp.Body.Close()
+		log.Check(err, "closing http response body")
+	}()
+	if resp.StatusCode != http.StatusOK {
+		// Error responses from the token endpoint have details, RFC 6749 section 5.2.
+		var e struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if err := json.NewDecoder(&moxio.LimitReader{R: resp.Body, Limit: 64 * 1024}).Decode(&e); err == nil && e.Error != "" {
+			return resp.StatusCode, fmt.Errorf("http status %s: %s: %s", resp.Status, e.Error, e.ErrorDescription)
+		}
+		return resp.StatusCode, fmt.Errorf("http status %s", resp.Status)
+	}
+	if err := json.NewDecoder(&moxio.LimitReader{R: resp.Body, Limit: 1024 * 1024}).Decode(v); err != nil {
+		return resp.StatusCode, fmt.Errorf("parsing response: %v", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// Discover fetches the configuration of the identity provider at issuer.
+func Discover(ctx context.Context, log mlog.Log, issuer string) (Provider, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", strings.TrimRight(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return Provider{}, fmt.Errorf("making request: %v", err)
+	}
+	var p Provider
+	if _, err := request(ctx, log, req, &p); err != nil {
+		return Provider{}, fmt.Errorf("fetching openid configuration: %v", err)
+	}
+	// OpenID Connect Discovery 1.0, section 4.3.
+	if p.Issuer != issuer {
+		return Provider{}, fmt.Errorf("openid configuration is for issuer %q, expected %q", p.Issuer, issuer)
+	}
+	for _, s := range []string{p.AuthorizationEndpoint, p.TokenEndpoint, p.UserinfoEndpoint} {
+		if u, err := url.Parse(s); err != nil || u.Scheme != "https" {
+			return Provider{}, fmt.Errorf("openid configuration with missing or non-https endpoint %q", s)
+		}
+	}
+	if p.IntrospectionEndpoint != "" {
+		if u, err := url.Parse(p.IntrospectionEndpoint); err != nil || u.Scheme != "https" {
+			return Provider{}, fmt.Errorf("openid configuration with non-https introspection endpoint %q", p.IntrospectionEndpoint)
+		}
+	}
+	return p, nil
+}
+
+// provider returns the configured provider, discovering it if needed. The
+// configuration is fetched again after an hour.
+func provider(ctx context.Context, log mlog.Log, c config.OIDC) (Provider, error) {
+	providerCache.Lock()
+	defer providerCache.Unlock()
+
+	if providerCache.issuer == c.Issuer && time.Since(providerCache.fetched) < time.Hour {
+		return providerCache.provider, nil
+	}
+	p, err := Discover(ctx, log, c.Issuer)
+	if err != nil {
+		return Provider{}, err
+	}
+	providerCache.issuer = c.Issuer
+	providerCache.provider = p
+	providerCache.fetched = time.Now()
+	return p, nil
+}
+
+// AuthCodeURL returns the URL at the identity provider to send the browser to
+// for a web login. State, nonce and verifier must be new random values for each
+// login. State is returned with the browser to redirectURI. Nonce and verifier
+// must be passed to Exchange.
+func AuthCodeURL(ctx context.Context, log mlog.Log, redirectURI, state, nonce, verifier string) (string, error) {
+	c, err := xconfig()
+	if err != nil {
+		return "", err
+	}
+	p, err := provider(ctx, log, c)
+	if err != nil {
+		return "", err
+	}
+
+	// PKCE, RFC 7636.
+	h := sha256.Sum256([]byte(verifier))
+	scopes := append([]string{"openid", "email"}, c.Scopes...)
+	q := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {c.ClientID},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(h[:])},
+		"code_challenge_method": {"S256"},
+	}
+	sep := "?"
+	if strings.Contains(p.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return p.AuthorizationEndpoint + sep + q.Encode(), nil
+}
+
+// Exchange exchanges the authorization code from a web login for tokens at the
+// identity provider, verifies the ID token, and returns the email address of the
+// user.
+//
+// The signature of the ID token is not verified: It is received directly from the
+// token endpoint of the provider over TLS, which authenticates the provider
+// (OpenID Connect Core 1.0, section 3.1.3.7).
+func Exchange(ctx context.Context, log mlog.Log, redirectURI, code, verifier, nonce string) (string, error) {
+	c, err := xconfig()
+	if err != nil {
+		return "", err
+	}
+	p, err := provider(ctx, log, c)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURI},
+		"code_verifier": {verifier},
+	}
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		IDToken     string `json:"id_token"`
+	}
+	if _, err := clientRequest(ctx, log, c, p.TokenEndpoint, form, &tokens); err != nil {
+		return "", fmt.Errorf("fetching tokens: %v", err)
+	}
+
+	claims, err := parseIDToken(tokens.IDToken)
+	if err != nil {
+		return "", err
+	}
+	if err := checkIDToken(claims, p.Issuer, c.ClientID, nonce, time.Now()); err != nil {
+		return "", err
+	}
+
+	if _, ok := claims[addressClaim(c)]; !ok && tokens.AccessToken != "" {
+		// Providers may only return the address through the userinfo endpoint.
+		info, err := userinfo(ctx, log, p, tokens.AccessToken)
+		if err != nil {
+			return "", fmt.Errorf("fetching userinfo: %v", err)
+		}
+		// OpenID Connect Core 1.0, section 5.3.2.
+		if sub, _ := info["sub"].(string); sub == "" || sub != claims["sub"] {
+			return "", fmt.Errorf("userinfo is for other subject")
+		}
+		claims = info
+	}
+	return claimAddress(c, claims)
+}
+
+// clientRequest does a form POST request to an endpoint of the identity
+// provider, authenticated as our client, parsing the JSON response into v.
+func clientRequest(ctx context.Context, log mlog.Log, c config.OIDC, endpoint string, form url.Values, v any) (int, error) {
+	if c.ClientSecret == "" {
+		form.Set("client_id", c.ClientID)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return 0, fmt.Errorf("making request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if c.ClientSecret != "" {
+		// RFC 6749 section 2.3.1.
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+	return request(ctx, log, req, v)
+}
+
+// parseIDToken returns the claims from the payload of a JWT ID token.
+func parseIDToken(token string) (map[string]any, error) {
+	t := strings.Split(token, ".")
+	if len(t) != 3 {
+		return nil, fmt.Errorf("missing or malformed id token")
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(t[1])
+	if err != nil {
+		return nil, fmt.Errorf("decoding id token payload: %v", err)
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(buf, &claims); err != nil {
+		return nil, fmt.Errorf("parsing id token payload: %v", err)
+	}
+	return claims, nil
+}
+
+// checkIDToken validates the claims of an ID token, OpenID Connect Core 1.0,
+// section 3.1.3.7.
+func checkIDToken(claims map[string]any, issuer, clientID, nonce string, now time.Time) error {
+	if iss, _ := claims["iss"].(string); iss != issuer {
+		return fmt.Errorf("id token from issuer %q, expected %q", iss, issuer)
+	}
+	audiences := claimAudiences(claims)
+	if !slices.Contains(audiences, clientID) {
+		return fmt.Errorf("id token not for our client id")
+	}
+	if azp, ok := claims["azp"].(string); (ok || len(audiences) > 1) && azp != clientID {
+		return fmt.Errorf("id token authorized for other party %q", azp)
+	}
+	if exp, _ := claims["exp"].(float64); now.After(time.Unix(int64(exp), 0)) {
+		return fmt.Errorf("id token expired")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return fmt.Errorf("id token has wrong nonce")
+	}
+	return nil
+}
+
+// claimAudiences returns the audiences from the "aud" claim, a string or an array
+// of strings.
+func claimAudiences(claims map[string]any) []string {
+	var audiences []string
+	switch aud := claims["aud"].(type) {
+	case string:
+		audiences = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	return audiences
+}
+
+func addressClaim(c config.OIDC) string {
+	if c.AddressClaim != "" {
+		return c.AddressClaim
+	}
+	return "email"
+}
+
+// claimAddress returns the email address from the claims. The identity provider
+// must have verified the address with claim email_verified, also for a custom
+// address claim: A missing claim is treated as not verified.
+func claimAddress(c config.OIDC, claims map[string]any) (string, error) {
+	name := addressClaim(c)
+	address, _ := claims[name].(string)
+	if address == "" {
+		return "", fmt.Errorf("no address in claim %q", name)
+	}
+	if verified, _ := claims["email_verified"].(bool); !verified {
+		return "", fmt.Errorf("email address %q not verified by identity provider", address)
+	}
+	return address, nil
+}
+
+// userinfo fetches the claims for an access token.
+func userinfo(ctx context.Context, log mlog.Log, p Provider, token string) (map[string]any, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.UserinfoEndpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("making request: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	var claims map[string]any
+	status, err := request(ctx, log, req, &claims)
+	if status == http.StatusUnauthorized || status == http.StatusForbidden {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	} else if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// introspect verifies an access token with token introspection, RFC 7662, and
+// returns its claims. The token must be active, and issued for our client id as
+// audience, or, without audience, to our client id. Otherwise a token issued for
+// another relying party at the same identity provider would be accepted.
+func introspect(ctx context.Context, log mlog.Log, c config.OIDC, p Provider, token string, now time.Time) (map[string]any, error) {
+	if p.IntrospectionEndpoint == "" {
+		return nil, fmt.Errorf("identity provider has no token introspection endpoint, required for verifying access tokens")
+	}
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {"access_token"},
+	}
+	var claims map[string]any
+	if _, err := clientRequest(ctx, log, c, p.IntrospectionEndpoint, form, &claims); err != nil {
+		return nil, fmt.Errorf("token introspection: %v", err)
+	}
+	if active, _ := claims["active"].(bool); !active {
+		return nil, fmt.Errorf("%w: token not active", ErrInvalidToken)
+	}
+	if iss, ok := claims["iss"].(string); ok && iss != p.Issuer {
+		return nil, fmt.Errorf("%w: token from issuer %q, expected %q", ErrInvalidToken, iss, p.Issuer)
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0)) {
+		return nil, fmt.Errorf("%w: token expired", ErrInvalidToken)
+	}
+	if audiences := claimAudiences(claims); len(audiences) > 0 {
+		if !slices.Contains(audiences, c.ClientID) {
+			return nil, fmt.Errorf("%w: token not for our client id", ErrInvalidToken)
+		}
+	} else if clientID, _ := claims["client_id"].(string); clientID != c.ClientID {
+		return nil, fmt.Errorf("%w: token without audience issued to other client %q", ErrInvalidToken, clientID)
+	}
+	return claims, nil
+}
+
+// TokenAddress verifies an OAuth 2.0 access token, as sent with SASL mechanisms
+// OAUTHBEARER and XOAUTH2, with token introspection at the identity provider, and
+// returns the email address of the user. The address is taken from the
+// introspection response, or from the userinfo for the token if absent. If the
+// token is not valid for us, an error wrapping ErrInvalidToken is returned.
+// Verified tokens are cached for a few minutes.
+func TokenAddress(ctx context.Context, log mlog.Log, token string) (string, error) {
+	c, err := xconfig()
+	if err != nil {
+		return "", err
+	}
+
+	key := sha256.Sum256([]byte(c.Issuer + "\n" + token))
+	tokenCache.Lock()
+	ct, ok := tokenCache.m[key]
+	tokenCache.Unlock()
+	if ok && time.Until(ct.expires) > 0 {
+		return ct.address, nil
+	}
+
+	p, err := provider(ctx, log, c)
+	if err != nil {
+		return "", err
+	}
+	claims, err := introspect(ctx, log, c, p, token, time.Now())
+	if err != nil {
+		return "", err
+	}
+	if _, ok := claims[addressClaim(c)]; !ok {
+		info, err := userinfo(ctx, log, p, token)
+		if err != nil {
+			return "", err
+		}
+		// OpenID Connect Core 1.0, section 5.3.2.
+		if sub, _ := info["sub"].(string); sub == "" || sub != claims["sub"] {
+			return "", fmt.Errorf("%w: userinfo is for other subject", ErrInvalidToken)
+		}
+		claims = info
+	}
+	address, err := claimAddress(c, claims)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	tokenCache.Lock()
+	defer tokenCache.Unlock()
+	now := time.Now()
+	for k, ct := range tokenCache.m {
+		if now.After(ct.expires) {
+			delete(tokenCache.m, k)
+		}
+	}
+	tokenCache.m[key] = cachedToken{address, now.Add(tokenCacheDuration)}
+	return address, nil
+}
@@ -0,0 +1,259 @@
+package oidc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+// idToken returns an unsigned JWT with claims.
+func idToken(claims map[string]any) string {
+	buf, err := json.Marshal(claims)
+	if err != nil {
+		panic(err)
+	}
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc(buf) + "." + enc([]byte("sig"))
+}
+
+func TestOIDC(t *testing.T) {
+	log := mlog.New("oidc", nil)
+
+	var issuer string
+	var nonce string
+	var introspectRequests int
+	var idClaims map[string]any
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(Provider{issuer, issuer + "/authorize", issuer + "/token", issuer + "/userinfo", issuer + "/introspect"})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "code1" || r.FormValue("client_id") != "mox" || r.FormValue("code_verifier") != "verifier1" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := map[string]any{
+			"iss":   issuer,
+			"aud":   "mox",
+			"sub":   "user1",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": nonce,
+		}
+		for k, v := range idClaims {
+			claims[k] = v
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "access1", "id_token": idToken(claims)})
+	})
+	mux.HandleFunc("/introspect", func(w http.ResponseWriter, r *http.Request) {
+		introspectRequests++
+		if r.FormValue("client_id") != "mox" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		claims := map[string]any{"active": true, "iss": issuer, "aud": "mox", "client_id": "mailclient", "exp": time.Now().Add(time.Minute).Unix()}
+		switch r.FormValue("token") {
+		case "access1":
+			claims["sub"] = "user1"
+		case "unverified":
+			claims["sub"] = "user2"
+		case "foreign":
+			// Token of another relying party at the same provider.
+			claims["sub"] = "user1"
+			claims["aud"] = "other"
+		case "noaudience":
+			delete(claims, "aud")
+			claims["client_id"] = "mox"
+			claims["email"] = "mox@mox.example"
+			claims["email_verified"] = true
+		case "noverified":
+			// Address in introspection response, but not marked as verified.
+			claims["sub"] = "user3"
+			claims["email"] = "mox@mox.example"
+		default:
+			claims = map[string]any{"active": false}
+		}
+		json.NewEncoder(w).Encode(claims)
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "Bearer access1":
+			json.NewEncoder(w).Encode(map[string]any{"sub": "user1", "email": "mjl@mox.example", "email_verified": true})
+		case "Bearer unverified":
+			json.NewEncoder(w).Encode(map[string]any{"sub": "user2", "email": "other@mox.example", "email_verified": false})
+		default:
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	})
+	srv := httptest.NewTLSServer(mux)
+	defer srv.Close()
+	issuer = srv.URL
+
+	origClient := HTTPClient
+	defer func() {
+		HTTPClient = origClient
+	}()
+	HTTPClient = srv.Client()
+
+	_, err := AuthCodeURL(ctxbg, log, "https://mox.example/webmail/oidc/callback", "state1", "nonce1", "verifier1")
+	if !errors.Is(err, ErrNotConfigured) {
+		t.Fatalf("got err %v, expected ErrNotConfigured", err)
+	}
+
+	mox.Conf.Static.OIDC = &config.OIDC{Issuer: issuer, ClientID: "mox"}
+	defer func() {
+		mox.Conf.Static.OIDC = nil
+	}()
+
+	_, err = Discover(ctxbg, log, issuer+"/other")
+	if err == nil {
+		t.Fatalf("discovery for other issuer accepted")
+	}
+
+	const redirectURI = "https://mox.example/webmail/oidc/callback"
+	s, err := AuthCodeURL(ctxbg, log, redirectURI, "state1", "nonce1", "verifier1")
+	tcheck(t, err, "auth code url")
+	u, err := url.Parse(s)
+	tcheck(t, err, "parse auth code url")
+	q := u.Query()
+	if u.Path != "/authorize" || q.Get("state") != "state1" || q.Get("nonce") != "nonce1" || q.Get("redirect_uri") != redirectURI || q.Get("code_challenge_method") != "S256" || q.Get("scope") != "openid email" {
+		t.Fatalf("unexpected auth code url %s", s)
+	}
+
+	// Address from userinfo, ID token has no email claim.
+	nonce = "nonce1"
+	address, err := Exchange(ctxbg, log, redirectURI, "code1", "verifier1", "nonce1")
+	tcheck(t, err, "exchange")
+	if address != "mjl@mox.example" {
+		t.Fatalf("got address %q, expected mjl@mox.example", address)
+	}
+
+	// Address from ID token.
+	idClaims = map[string]any{"email": "mox@mox.example", "email_verified": true}
+	address, err = Exchange(ctxbg, log, redirectURI, "code1", "verifier1", "nonce1")
+	tcheck(t, err, "exchange")
+	if address != "mox@mox.example" {
+		t.Fatalf("got address %q, expected mox@mox.example", address)
+	}
+
+	if _, err := Exchange(ctxbg, log, redirectURI, "code1", "verifier1", "other"); err == nil {
+		t.Fatalf("exchange with wrong nonce accepted")
+	}
+	if _, err := Exchange(ctxbg, log, redirectURI, "bogus", "verifier1", "nonce1"); err == nil || !strings.Contains(err.Error(), "invalid_grant") {
+		t.Fatalf("got err %v, expected invalid_grant", err)
+	}
+	idClaims = map[string]any{"email": "mox@mox.example", "email_verified": false}
+	if _, err := Exchange(ctxbg, log, redirectURI, "code1", "verifier1", "nonce1"); err == nil {
+		t.Fatalf("exchange with unverified address accepted")
+	}
+	idClaims = map[string]any{"email": "mox@mox.example"}
+	if _, err := Exchange(ctxbg, log, redirectURI, "code1", "verifier1", "nonce1"); err == nil {
+		t.Fatalf("exchange with address without email_verified accepted")
+	}
+
+	address, err = TokenAddress(ctxbg, log, "access1")
+	tcheck(t, err, "token address")
+	if address != "mjl@mox.example" {
+		t.Fatalf("got address %q, expected mjl@mox.example", address)
+	}
+	n := introspectRequests
+	_, err = TokenAddress(ctxbg, log, "access1")
+	tcheck(t, err, "token address")
+	if introspectRequests != n {
+		t.Fatalf("token not cached")
+	}
+	address, err = TokenAddress(ctxbg, log, "noaudience")
+	tcheck(t, err, "token address without audience")
+	if address != "mox@mox.example" {
+		t.Fatalf("got address %q, expected mox@mox.example", address)
+	}
+	for _, token := range []string{"bogus", "unverified", "foreign", "noverified"} {
+		if _, err := TokenAddress(ctxbg, log, token); !errors.Is(err, ErrInvalidToken) {
+			t.Fatalf("token %q: got err %v, expected ErrInvalidToken", token, err)
+		}
+	}
+}
+
+func TestCheckIDToken(t *testing.T) {
+	now := time.Now()
+	exp := float64(now.Add(time.Minute).Unix())
+	valid := func(claims map[string]any) bool {
+		return checkIDToken(claims, "https://sso.example", "mox", "n", now) == nil
+	}
+	if !valid(map[string]any{"iss": "https://sso.example", "aud": "mox", "exp": exp, "nonce": "n"}) {
+		t.Fatalf("valid id token rejected")
+	}
+	if !valid(map[string]any{"iss": "https://sso.example", "aud": []any{"mox", "other"}, "azp": "mox", "exp": exp, "nonce": "n"}) {
+		t.Fatalf("valid id token with multiple audiences rejected")
+	}
+	bad := []map[string]any{
+		{"iss": "https://other.example", "aud": "mox", "exp": exp, "nonce": "n"},
+		{"iss": "https://sso.example", "aud": "other", "exp": exp, "nonce": "n"},
+		{"iss": "https://sso.example", "aud": []any{"mox", "other"}, "exp": exp, "nonce": "n"},
+		{"iss": "https://sso.example", "aud": "mox", "azp": "other", "exp": exp, "nonce": "n"},
+		{"iss": "https://sso.example", "aud": "mox", "exp": float64(now.Add(-time.Minute).Unix()), "nonce": "n"},
+		{"iss": "https://sso.example", "aud": "mox", "nonce": "n"},
+		{"iss": "https://sso.example", "aud": "mox", "exp": exp},
+	}
+	for _, claims := range bad {
+		if valid(claims) {
+			t.Fatalf("bad id token %v accepted", claims)
+		}
+	}
+}
+
+func TestParseSASL(t *testing.T) {
+	authz, token, err := ParseOAuthBearer([]byte("n,a=mjl@mox.example,\x01host=mox.example\x01port=993\x01auth=Bearer token1\x01\x01"))
+	tcheck(t, err, "parse oauthbearer")
+	if authz != "mjl@mox.example" || token != "token1" {
+		t.Fatalf("got authz %q, token %q", authz, token)
+	}
+	authz, token, err = ParseOAuthBearer([]byte("n,,\x01auth=bearer token1\x01\x01"))
+	tcheck(t, err, "parse oauthbearer")
+	if authz != "" || token != "token1" {
+		t.Fatalf("got authz %q, token %q", authz, token)
+	}
+	for _, s := range []string{
+		"",
+		"\x01", // Dummy response after error.
+		"p=tls-unique,,\x01auth=Bearer t\x01\x01", // Channel binding.
+		"n,,\x01host=mox.example\x01\x01",         // No auth.
+		"n,,\x01auth=Basic dGVzdA==\x01\x01",
+		"n,,\x01auth=Bearer t\x01",
+	} {
+		if _, _, err := ParseOAuthBearer([]byte(s)); err == nil {
+			t.Fatalf("parsing oauthbearer %q: expected error", s)
+		}
+	}
+
+	user, token, err := ParseXOAUTH2([]byte("user=mjl@mox.example\x01auth=Bearer token1\x01\x01"))
+	tcheck(t, err, "parse xoauth2")
+	if user != "mjl@mox.example" || token != "token1" {
+		t.Fatalf("got user %q, token %q", user, token)
+	}
+	for _, s := range []string{"", "user=mjl@mox.example\x01\x01", "user=mjl@mox.example\x01auth=Bearer token1"} {
+		if _, _, err := ParseXOAUTH2([]byte(s)); err == nil {
+			t.Fatalf("parsing xoauth2 %q: expected error", s)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package oidc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mjl-/mox/mox-"
+)
+
+// bearerToken returns the token from an "auth" value, "Bearer <token>".
+func bearerToken(v string) (string, error) {
+	scheme, token, _ := strings.Cut(v, " ")
+	if !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", fmt.Errorf("auth value not a bearer token")
+	}
+	return token, nil
+}
+
+// ParseOAuthBearer parses the initial client response of SASL mechanism
+// OAUTHBEARER, RFC 7628 section 3.1, returning the optional authorization
+// identity and the bearer token. Channel binding is not supported.
+func ParseOAuthBearer(buf []byte) (authz, token string, rerr error) {
+	// GS2 header, RFC 5801 section 4, followed by key/value pairs.
+	t := strings.SplitN(string(buf), ",", 3)
+	if len(t) != 3 {
+		return "", "", fmt.Errorf("missing gs2 header")
+	}
+	if t[0] != "n" && t[0] != "y" {
+		return "", "", fmt.Errorf("channel binding not supported")
+	}
+	if t[1] != "" {
+		a, ok := strings.CutPrefix(t[1], "a=")
+		if !ok {
+			return "", "", fmt.Errorf("bad authorization identity in gs2 header")
+		}
+		// RFC 5801 section 4, saslname.
+		authz = strings.NewReplacer("=2C", ",", "=3D", "=").Replace(a)
+	}
+	kvs, ok := strings.CutPrefix(t[2], "\x01")
+	if ok {
+		kvs, ok = strings.CutSuffix(kvs, "\x01\x01")
+	}
+	if !ok {
+		return "", "", fmt.Errorf("malformed key/value pairs")
+	}
+	for _, kv := range strings.Split(kvs, "\x01") {
+		if k, v, _ := strings.Cut(kv, "="); k == "auth" {
+			token, err := bearerToken(v)
+			return authz, token, err
+		}
+	}
+	return "", "", fmt.Errorf("missing auth key")
+}
+
+// ParseXOAUTH2 parses the client response of SASL mechanism XOAUTH2, as
+// introduced by Google, returning the user and bearer token. The format is
+// "user=<address>\x01auth=Bearer <token>\x01\x01".
+func ParseXOAUTH2(buf []byte) (user, token string, rerr error) {
+	kvs, ok := strings.CutSuffix(string(buf), "\x01\x01")
+	if !ok {
+		return "", "", fmt.Errorf("malformed key/value pairs")
+	}
+	for _, kv := range strings.Split(kvs, "\x01") {
+		k, v, _ := strings.Cut(kv, "=")
+		switch k {
+		case "user":
+			user = v
+		case "auth":
+			var err error
+			if token, err = bearerToken(v); err != nil {
+				return "", "", err
+			}
+		}
+	}
+	if token == "" {
+		return "", "", fmt.Errorf("missing auth key")
+	}
+	return user, token, nil
+}
+
+// ErrorChallenge returns the server challenge for a failed OAUTHBEARER or XOAUTH2
+// authentication, RFC 7628 section 3.2.2. The client must respond, after which the
+// server fails the authentication.
+func ErrorChallenge() []byte {
+	var v struct {
+		Status              string `json:"status"`
+		Scope               string `json:"scope"`
+		OpenIDConfiguration string `json:"openid-configuration,omitempty"`
+	}
+	v.Status = "invalid_token"
+	v.Scope = "openid email"
+	if c := mox.Conf.Static.OIDC; c != nil {
+		v.OpenIDConfiguration = strings.TrimRight(c.Issuer, "/") + "/.well-known/openid-configuration"
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+	return buf
+}
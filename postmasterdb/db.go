@@ -0,0 +1,129 @@
+// Package postmasterdb fetches and stores reputation data about our outgoing
+// email from mailbox providers.
+//
+// Microsoft provides per-IP data through Smart Network Data Services (SNDS),
+// Google provides per-domain data through the Postmaster Tools API. Both are
+// fetched daily if configured in mox.conf, and presented to the admin along with
+// the volume of our own deliveries to those providers.
+package postmasterdb
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+)
+
+var (
+	DBTypes = []any{SNDSRecord{}, GoogleStats{}}
+	DB      *bstore.DB
+)
+
+// SNDSRecord holds the Microsoft SNDS data for one IP and activity period
+// (typically a day).
+type SNDSRecord struct {
+	ID int64
+
+	IP                string    `bstore:"unique IP+ActivityStart,nonzero"`
+	ActivityStart     time.Time `bstore:"index"`
+	ActivityEnd       time.Time
+	RcptCommands      int
+	DataCommands      int
+	MessageRecipients int
+	FilterResult      string // GREEN, YELLOW or RED, the fraction of messages marked as spam.
+	ComplaintRate     string // E.g. "< 0.1%".
+	TrapPeriodStart   time.Time
+	TrapPeriodEnd     time.Time
+	TrapHits          int
+	SampleHELO        string
+	SampleMailFrom    string
+	Comments          string
+
+	Updated time.Time `bstore:"default now"`
+}
+
+// GoogleStats holds the Google Postmaster Tools traffic statistics for one
+// domain and day.
+type GoogleStats struct {
+	ID int64
+
+	Domain string `bstore:"unique Domain+DayUTC,nonzero"` // As registered at Google, lower case.
+	DayUTC string `bstore:"index,nonzero"`                // Of the form yyyymmdd.
+
+	UserReportedSpamRatio   float64
+	DomainReputation        string // HIGH, MEDIUM, LOW, BAD, or empty.
+	IPReputations           []GoogleIPReputation
+	SPFSuccessRatio         float64
+	DKIMSuccessRatio        float64
+	DMARCSuccessRatio       float64
+	OutboundEncryptionRatio float64
+	DeliveryErrors          []GoogleDeliveryError
+
+	Updated time.Time `bstore:"default now"`
+}
+
+// GoogleIPReputation is the number of our IPs with a reputation.
+type GoogleIPReputation struct {
+	Reputation string // HIGH, MEDIUM, LOW or BAD.
+	IPCount    int64
+	SampleIPs  []string
+}
+
+// GoogleDeliveryError is the ratio of messages rejected or temporarily failed
+// for a class and type of error.
+type GoogleDeliveryError struct {
+	ErrorClass string // PERMANENT_ERROR or TEMPORARY_ERROR.
+	ErrorType  string // E.g. RATE_LIMIT_EXCEEDED, SUSPECTED_SPAM, DMARC_POLICY.
+	ErrorRatio float64
+}
+
+// Init opens the database.
+func Init() error {
+	if DB != nil {
+		return fmt.Errorf("already initialized")
+	}
+
+	log := mlog.New("postmasterdb", nil)
+	p := mox.DataDirPath("postmaster.db")
+	os.MkdirAll(filepath.Dir(p), 0770)
+	opts := bstore.Options{Timeout: 5 * time.Second, Perm: 0660, RegisterLogger: moxvar.RegisterLogger(p, log.Logger)}
+	var err error
+	DB, err = bstore.Open(mox.Shutdown, p, &opts, DBTypes...)
+	return err
+}
+
+// Close closes the database.
+func Close() error {
+	if err := DB.Close(); err != nil {
+		return fmt.Errorf("closing db: %w", err)
+	}
+	DB = nil
+	return nil
+}
+
+// SNDSList returns SNDS records with activity starting in the given period,
+// most recent first.
+func SNDSList(ctx context.Context, start, end time.Time) ([]SNDSRecord, error) {
+	q := bstore.QueryDB[SNDSRecord](ctx, DB)
+	q.FilterGreaterEqual("ActivityStart", start)
+	q.FilterLess("ActivityStart", end)
+	q.SortDesc("ActivityStart")
+	return q.List()
+}
+
+// GoogleList returns Google Postmaster Tools statistics for the days in the given
+// period, most recent first.
+func GoogleList(ctx context.Context, start, end time.Time) ([]GoogleStats, error) {
+	q := bstore.QueryDB[GoogleStats](ctx, DB)
+	q.FilterGreaterEqual("DayUTC", start.UTC().Format("20060102"))
+	q.FilterLess("DayUTC", end.UTC().Format("20060102"))
+	q.SortDesc("DayUTC")
+	return q.List()
+}
@@ -0,0 +1,118 @@
+package postmasterdb
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+var ctxbg = context.Background()
+
+func tcheckf(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", fmt.Sprintf(format, args...), err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestFetch(t *testing.T) {
+	mox.Shutdown = ctxbg
+	mox.Conf.Static.DataDir = t.TempDir()
+	log := mlog.New("postmasterdb", nil)
+
+	err := Init()
+	tcheckf(t, err, "init database")
+	defer Close()
+
+	const sndsData = `192.0.2.1,10/14/2026 12:00 AM,10/14/2026 11:59 PM,100,95,120,GREEN,< 0.1%,,,0,mail.mox.example,mjl@mox.example,
+192.0.2.2,10/14/2026 12:00 AM,10/14/2026 11:59 PM,10,10,10,Red,3.2%,10/14/2026 1:00 AM,10/14/2026 2:00 PM,4,mail.mox.example,,
+`
+	const googleTraffic = `{"trafficStats": [{"name": "domains/mox.example/trafficStats/20261014", "userReportedSpamRatio": 0.001, "ipReputations": [{"reputation": "HIGH", "ipCount": "1", "sampleIps": ["192.0.2.1"]}], "domainReputation": "HIGH", "spfSuccessRatio": 1, "dkimSuccessRatio": 1, "dmarcSuccessRatio": 0.99, "deliveryErrors": [{"errorClass": "TEMPORARY_ERROR", "errorType": "RATE_LIMIT_EXCEEDED", "errorRatio": 0.01}]}]}`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/snds", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("key") != "sndskey" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, sndsData)
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("refresh_token") != "refreshtoken" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "accesstoken", "expires_in": 3600}`)
+	})
+	mux.HandleFunc("/v1/domains", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"domains": [{"name": "domains/mox.example"}]}`)
+	})
+	mux.HandleFunc("/v1/domains/mox.example/trafficStats", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer accesstoken" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, googleTraffic)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	sndsURL = ts.URL + "/snds"
+	googleTokenURL = ts.URL + "/token"
+	googleAPIURL = ts.URL + "/v1"
+
+	_, err = fetchSNDS(ctxbg, log, "badkey")
+	if err == nil {
+		t.Fatalf("fetching snds with bad key succeeded")
+	}
+	pt := config.PostmasterTools{SNDSKey: "sndskey", GoogleClientID: "id", GoogleClientSecret: "secret", GoogleRefreshToken: "refreshtoken"}
+	refresh(ctxbg, log, pt)
+	// Fetching again updates the records in place.
+	refresh(ctxbg, log, pt)
+
+	start := time.Date(2026, 10, 14, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	snds, err := SNDSList(ctxbg, start, end)
+	tcheckf(t, err, "list snds")
+	tcompare(t, len(snds), 2)
+	tcompare(t, snds[0].IP != snds[1].IP, true)
+	for _, r := range snds {
+		if r.IP == "192.0.2.2" {
+			tcompare(t, r.FilterResult, "RED")
+			tcompare(t, r.TrapHits, 4)
+			tcompare(t, r.TrapPeriodStart, time.Date(2026, 10, 14, 1, 0, 0, 0, time.UTC))
+		}
+	}
+
+	gl, err := GoogleList(ctxbg, start, end)
+	tcheckf(t, err, "list google stats")
+	tcompare(t, len(gl), 1)
+	tcompare(t, gl[0].Domain, "mox.example")
+	tcompare(t, gl[0].DayUTC, "20261014")
+	tcompare(t, gl[0].DomainReputation, "HIGH")
+	tcompare(t, gl[0].IPReputations[0].IPCount, 1)
+	tcompare(t, gl[0].DeliveryErrors[0].ErrorType, "RATE_LIMIT_EXCEEDED")
+
+	gl, err = GoogleList(ctxbg, end, end.AddDate(0, 0, 1))
+	tcheckf(t, err, "list google stats")
+	tcompare(t, len(gl), 0)
+
+	tcompare(t, provider("gmail.com"), "google")
+	tcompare(t, provider("hotmail.co.uk"), "microsoft")
+	tcompare(t, provider("mox.example"), "")
+}
@@ -0,0 +1,218 @@
+package postmasterdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+)
+
+// Tests override these.
+var (
+	googleTokenURL = "https://oauth2.googleapis.com/token"
+	googleAPIURL   = "https://gmailpostmastertools.googleapis.com/v1"
+)
+
+// Days of Google Postmaster Tools statistics to fetch on each refresh. Google
+// fills in data for a day with a delay, so we fetch more than a single day.
+const googleDays = 7
+
+// googleGet does an HTTP request and parses the JSON response into v.
+func googleGet(ctx context.Context, log mlog.Log, req *http.Request, v any) error {
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if resp == nil {
+		resp = &http.Response{StatusCode: 0}
+	}
+	HTTPClientObserve(ctx, log.Logger, "postmasterdb", req.Method, resp.StatusCode, err, start)
+	if err != nil {
+		return fmt.Errorf("http request: %v", err)
+	}
+	defer func() {
+		err := resp.Body.Close()
+		log.Check(err, "closing http response body")
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("http status: %s", resp.Status)
+	}
+	if err := json.NewDecoder(&moxio.LimitReader{R: resp.Body, Limit: 10 * 1024 * 1024}).Decode(v); err != nil {
+		return fmt.Errorf("parsing response: %v", err)
+	}
+	return nil
+}
+
+// googleToken exchanges the configured refresh token for an access token.
+func googleToken(ctx context.Context, log mlog.Log, pt config.PostmasterTools) (string, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {pt.GoogleClientID},
+		"client_secret": {pt.GoogleClientSecret},
+		"refresh_token": {pt.GoogleRefreshToken},
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("making request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err := googleGet(ctx, log, req, &token); err != nil {
+		return "", err
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("no access token in response")
+	}
+	return token.AccessToken, nil
+}
+
+// fetchGoogle fetches the traffic statistics of the past days for all domains
+// registered at Google Postmaster Tools, and stores them.
+func fetchGoogle(ctx context.Context, log mlog.Log, pt config.PostmasterTools, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	token, err := googleToken(ctx, log, pt)
+	if err != nil {
+		return 0, fmt.Errorf("fetching access token: %v", err)
+	}
+
+	// Fetch all pages for an API path, calling nextPageToken after each response to
+	// process it.
+	list := func(path string, params url.Values, v any, nextPageToken func() string) error {
+		var pageToken string
+		for {
+			if pageToken != "" {
+				params.Set("pageToken", pageToken)
+			}
+			req, err := http.NewRequestWithContext(ctx, "GET", googleAPIURL+path+"?"+params.Encode(), nil)
+			if err != nil {
+				return fmt.Errorf("making request: %v", err)
+			}
+			req.Header.Set("Authorization", "Bearer "+token)
+			if err := googleGet(ctx, log, req, v); err != nil {
+				return err
+			}
+			pageToken = nextPageToken()
+			if pageToken == "" {
+				return nil
+			}
+		}
+	}
+
+	var domains []string
+	var dresp struct {
+		Domains []struct {
+			Name string `json:"name"` // "domains/<domain>"
+		} `json:"domains"`
+		NextPageToken string `json:"nextPageToken"`
+	}
+	err = list("/domains", url.Values{}, &dresp, func() string {
+		for _, d := range dresp.Domains {
+			domains = append(domains, strings.TrimPrefix(d.Name, "domains/"))
+		}
+		dresp.Domains = nil
+		return dresp.NextPageToken
+	})
+	if err != nil {
+		return 0, fmt.Errorf("listing domains: %v", err)
+	}
+
+	startDay := now.UTC().AddDate(0, 0, -googleDays)
+	endDay := now.UTC()
+	params := url.Values{}
+	params.Set("startDate.year", fmt.Sprint(startDay.Year()))
+	params.Set("startDate.month", fmt.Sprint(int(startDay.Month())))
+	params.Set("startDate.day", fmt.Sprint(startDay.Day()))
+	params.Set("endDate.year", fmt.Sprint(endDay.Year()))
+	params.Set("endDate.month", fmt.Sprint(int(endDay.Month())))
+	params.Set("endDate.day", fmt.Sprint(endDay.Day()))
+
+	var stats []GoogleStats
+	for _, d := range domains {
+		var sresp struct {
+			TrafficStats  []googleTrafficStats `json:"trafficStats"`
+			NextPageToken string               `json:"nextPageToken"`
+		}
+		err := list("/domains/"+url.PathEscape(d)+"/trafficStats", params, &sresp, func() string {
+			for _, ts := range sresp.TrafficStats {
+				stats = append(stats, ts.stats(d))
+			}
+			sresp.TrafficStats = nil
+			return sresp.NextPageToken
+		})
+		if err != nil {
+			return 0, fmt.Errorf("listing traffic stats for domain %s: %v", d, err)
+		}
+		params.Del("pageToken")
+	}
+
+	err = DB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, s := range stats {
+			o, err := bstore.QueryTx[GoogleStats](tx).FilterNonzero(GoogleStats{Domain: s.Domain, DayUTC: s.DayUTC}).Get()
+			if err == nil {
+				s.ID = o.ID
+				s.Updated = time.Now()
+				err = tx.Update(&s)
+			} else if errors.Is(err, bstore.ErrAbsent) {
+				err = tx.Insert(&s)
+			}
+			if err != nil {
+				return fmt.Errorf("storing google stats: %v", err)
+			}
+		}
+		return nil
+	})
+	return len(stats), err
+}
+
+// googleTrafficStats is a TrafficStats resource from the Postmaster Tools API.
+type googleTrafficStats struct {
+	Name                  string  `json:"name"` // "domains/<domain>/trafficStats/<yyyymmdd>"
+	UserReportedSpamRatio float64 `json:"userReportedSpamRatio"`
+	IPReputations         []struct {
+		Reputation string   `json:"reputation"`
+		IPCount    int64    `json:"ipCount,string"`
+		SampleIPs  []string `json:"sampleIps"`
+	} `json:"ipReputations"`
+	DomainReputation        string  `json:"domainReputation"`
+	SPFSuccessRatio         float64 `json:"spfSuccessRatio"`
+	DKIMSuccessRatio        float64 `json:"dkimSuccessRatio"`
+	DMARCSuccessRatio       float64 `json:"dmarcSuccessRatio"`
+	OutboundEncryptionRatio float64 `json:"outboundEncryptionRatio"`
+	DeliveryErrors          []struct {
+		ErrorClass string  `json:"errorClass"`
+		ErrorType  string  `json:"errorType"`
+		ErrorRatio float64 `json:"errorRatio"`
+	} `json:"deliveryErrors"`
+}
+
+func (ts googleTrafficStats) stats(domain string) GoogleStats {
+	s := GoogleStats{
+		Domain:                  strings.ToLower(domain),
+		DayUTC:                  ts.Name[strings.LastIndex(ts.Name, "/")+1:],
+		UserReportedSpamRatio:   ts.UserReportedSpamRatio,
+		DomainReputation:        ts.DomainReputation,
+		SPFSuccessRatio:         ts.SPFSuccessRatio,
+		DKIMSuccessRatio:        ts.DKIMSuccessRatio,
+		DMARCSuccessRatio:       ts.DMARCSuccessRatio,
+		OutboundEncryptionRatio: ts.OutboundEncryptionRatio,
+	}
+	for _, r := range ts.IPReputations {
+		s.IPReputations = append(s.IPReputations, GoogleIPReputation{r.Reputation, r.IPCount, r.SampleIPs})
+	}
+	for _, e := range ts.DeliveryErrors {
+		s.DeliveryErrors = append(s.DeliveryErrors, GoogleDeliveryError{e.ErrorClass, e.ErrorType, e.ErrorRatio})
+	}
+	return s
+}
@@ -0,0 +1,146 @@
+package postmasterdb
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/stub"
+)
+
+var (
+	HTTPClientObserve func(ctx context.Context, log *slog.Logger, pkg, method string, statusCode int, err error, start time.Time) = stub.HTTPClientObserveIgnore
+)
+
+// Start starts a goroutine that fetches reputation data from the configured
+// providers immediately and every 24 hours after.
+func Start() {
+	pt := mox.Conf.Static.PostmasterTools
+	if pt == nil {
+		return
+	}
+
+	go func() {
+		log := mlog.New("postmasterdb", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Postmasterdb)
+			}
+		}()
+
+		ticker := time.NewTicker(24 * time.Hour)
+		defer ticker.Stop()
+		for {
+			refresh(mox.Shutdown, log.WithCid(mox.Cid()), *pt)
+
+			select {
+			case <-mox.Shutdown.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func refresh(ctx context.Context, log mlog.Log, pt config.PostmasterTools) {
+	if pt.SNDSKey != "" {
+		n, err := fetchSNDS(ctx, log, pt.SNDSKey)
+		log.Check(err, "fetching snds data")
+		log.Debug("fetched snds data", slog.Int("records", n))
+	}
+	if pt.GoogleRefreshToken != "" {
+		n, err := fetchGoogle(ctx, log, pt, time.Now())
+		log.Check(err, "fetching google postmaster tools data")
+		log.Debug("fetched google postmaster tools data", slog.Int("records", n))
+	}
+}
+
+// Volume is the number of our outgoing deliveries to a provider for a day and
+// sender domain.
+type Volume struct {
+	DayUTC       string // Of the form yyyymmdd.
+	Provider     string // "google" or "microsoft".
+	SenderDomain string // Unicode.
+	Delivered    int
+	Failed       int
+}
+
+// provider returns the mailbox provider for well-known consumer email domains of
+// providers we fetch data for, or an empty string.
+func provider(domain string) string {
+	switch {
+	case domain == "gmail.com" || domain == "googlemail.com":
+		return "google"
+	case domain == "outlook.com" || domain == "msn.com" || strings.HasPrefix(domain, "hotmail.") || strings.HasPrefix(domain, "live.") || strings.HasPrefix(domain, "outlook."):
+		return "microsoft"
+	}
+	return ""
+}
+
+// VolumeList returns our outgoing delivery volume per day to well-known
+// provider domains in the given period, for correlating with the reputation
+// data. The volume is derived from retired messages in the queue, so is only
+// available when retired messages are kept, see KeepRetiredMessagePeriod in
+// the account configuration.
+func VolumeList(ctx context.Context, start, end time.Time) ([]Volume, error) {
+	type key struct {
+		day, provider, senderDomain string
+	}
+	volumes := map[key]*Volume{}
+
+	q := bstore.QueryDB[queue.MsgRetired](ctx, queue.DB)
+	q.FilterGreaterEqual("LastActivity", start)
+	q.FilterLess("LastActivity", end)
+	err := q.ForEach(func(m queue.MsgRetired) error {
+		p := provider(m.RecipientDomainStr)
+		if p == "" {
+			return nil
+		}
+		k := key{m.LastActivity.UTC().Format("20060102"), p, m.SenderDomainStr}
+		v := volumes[k]
+		if v == nil {
+			v = &Volume{DayUTC: k.day, Provider: k.provider, SenderDomain: k.senderDomain}
+			volumes[k] = v
+		}
+		if m.Success {
+			v.Delivered++
+		} else {
+			v.Failed++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	l := make([]Volume, 0, len(volumes))
+	for _, v := range volumes {
+		l = append(l, *v)
+	}
+	sort.Slice(l, func(i, j int) bool {
+		a, b := l[i], l[j]
+		if a.DayUTC != b.DayUTC {
+			return a.DayUTC > b.DayUTC
+		}
+		if a.Provider != b.Provider {
+			return a.Provider < b.Provider
+		}
+		return a.SenderDomain < b.SenderDomain
+	})
+	return l, nil
+}
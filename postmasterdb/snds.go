@@ -0,0 +1,146 @@
+package postmasterdb
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+)
+
+// Tests override this.
+var sndsURL = "https://sendersupport.olc.protection.outlook.com/snds/data.aspx"
+
+// fetchSNDS fetches the most recent SNDS data for the key and stores it.
+func fetchSNDS(ctx context.Context, log mlog.Log, key string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", sndsURL+"?key="+url.QueryEscape(key), nil)
+	if err != nil {
+		return 0, fmt.Errorf("making request: %v", err)
+	}
+	start := time.Now()
+	resp, err := http.DefaultClient.Do(req)
+	if resp == nil {
+		resp = &http.Response{StatusCode: 0}
+	}
+	HTTPClientObserve(ctx, log.Logger, "postmasterdb", req.Method, resp.StatusCode, err, start)
+	if err != nil {
+		return 0, fmt.Errorf("http request: %v", err)
+	}
+	defer func() {
+		err := resp.Body.Close()
+		log.Check(err, "closing http response body")
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("http status: %s", resp.Status)
+	}
+
+	records, err := parseSNDS(&moxio.LimitReader{R: resp.Body, Limit: 10 * 1024 * 1024})
+	if err != nil {
+		return 0, fmt.Errorf("parsing snds data: %v", err)
+	}
+
+	err = DB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, r := range records {
+			q := bstore.QueryTx[SNDSRecord](tx)
+			q.FilterNonzero(SNDSRecord{IP: r.IP})
+			q.FilterEqual("ActivityStart", r.ActivityStart)
+			o, err := q.Get()
+			if err == nil {
+				r.ID = o.ID
+				r.Updated = time.Now()
+				err = tx.Update(&r)
+			} else if errors.Is(err, bstore.ErrAbsent) {
+				err = tx.Insert(&r)
+			}
+			if err != nil {
+				return fmt.Errorf("storing snds record: %v", err)
+			}
+		}
+		return nil
+	})
+	return len(records), err
+}
+
+// parseSNDS parses SNDS data, a CSV file without header with a line per IP and
+// activity period.
+func parseSNDS(r io.Reader) ([]SNDSRecord, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	parseTime := func(s string) (time.Time, error) {
+		if s == "" {
+			return time.Time{}, nil
+		}
+		return time.ParseInLocation("1/2/2006 3:04 PM", s, time.UTC)
+	}
+
+	var l []SNDSRecord
+	for {
+		fields, err := cr.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(fields) < 12 {
+			return nil, fmt.Errorf("line %d: got %d fields, expected at least 12", len(l)+1, len(fields))
+		}
+		var xerr error
+		xtime := func(s string) time.Time {
+			t, err := parseTime(s)
+			if err != nil && xerr == nil {
+				xerr = err
+			}
+			return t
+		}
+		xint := func(s string) int {
+			if s == "" {
+				return 0
+			}
+			v, err := strconv.Atoi(s)
+			if err != nil && xerr == nil {
+				xerr = err
+			}
+			return v
+		}
+		r := SNDSRecord{
+			IP:                fields[0],
+			ActivityStart:     xtime(fields[1]),
+			ActivityEnd:       xtime(fields[2]),
+			RcptCommands:      xint(fields[3]),
+			DataCommands:      xint(fields[4]),
+			MessageRecipients: xint(fields[5]),
+			FilterResult:      strings.ToUpper(fields[6]),
+			ComplaintRate:     fields[7],
+			TrapPeriodStart:   xtime(fields[8]),
+			TrapPeriodEnd:     xtime(fields[9]),
+			TrapHits:          xint(fields[10]),
+			SampleHELO:        fields[11],
+		}
+		if len(fields) > 12 {
+			r.SampleMailFrom = fields[12]
+		}
+		if len(fields) > 13 {
+			r.Comments = fields[13]
+		}
+		if xerr != nil {
+			return nil, fmt.Errorf("line %d: %v", len(l)+1, xerr)
+		}
+		l = append(l, r)
+	}
+	return l, nil
+}
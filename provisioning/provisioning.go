@@ -0,0 +1,501 @@
+/*
+Package provisioning implements the provisioning API, a REST/JSON API for
+managing accounts and their addresses, for hosting panels and automation.
+
+The API is served at provisioning/v0/ below the admin web interface, and only
+when enabled in mox.conf. Requests are authenticated with a bearer token in the
+Authorization header. Tokens are managed with "mox provisioning token".
+
+Endpoints:
+
+	GET    v0/accounts                             List accounts.
+	POST   v0/accounts                             Create account, body AccountCreate.
+	GET    v0/accounts/<name>                      Get account.
+	DELETE v0/accounts/<name>                      Remove account.
+	POST   v0/accounts/<name>/suspend              Suspend account, body Suspend.
+	POST   v0/accounts/<name>/activate             Activate suspended account.
+	PUT    v0/accounts/<name>/quota                Set quota, body Quota.
+	POST   v0/accounts/<name>/addresses            Add address, body AddressAdd.
+	DELETE v0/accounts/<name>/addresses/<address>  Remove address.
+
+Successful responses have the account, or a list of accounts, as JSON. Errors
+have a non-2xx status code and an Error as JSON.
+
+Requests that make changes can have an "Idempotency-Key" header. The response to
+the first request with a key is stored for 24 hours, and returned for repeated
+requests with the same key, without making changes again. Reusing a key for a
+different request results in an error.
+
+If a webhook URL is configured, a Notification is sent for each change.
+*/
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/admin"
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webauth"
+)
+
+var pkglog = mlog.New("provisioning", nil)
+
+// Lifecycle states of accounts.
+const (
+	StateActive    = "active"
+	StateSuspended = "suspended" // Logins are disabled, incoming email is still accepted.
+	StateDeleted   = "deleted"   // In responses and notifications for removed accounts.
+)
+
+// Account is an account as returned by the API.
+type Account struct {
+	Name           string
+	State          string // StateActive or StateSuspended.
+	SuspendMessage string `json:",omitempty"` // Shown to users trying to log in while suspended.
+	Addresses      []string
+	Quota          int64 // Maximum total size of messages in bytes. 0 for the default, negative for no limit.
+	DiskUsage      int64 // Total size of messages in bytes.
+}
+
+// AccountCreate is the request body for creating an account.
+type AccountCreate struct {
+	Name     string
+	Address  string // Initial email address, its domain must be configured.
+	Password string `json:",omitempty"` // Optional. Without password, the account cannot log in.
+	Quota    int64  `json:",omitempty"`
+}
+
+// Suspend is the request body for suspending an account.
+type Suspend struct {
+	Message string // Optional, shown to users trying to log in.
+}
+
+// Quota is the request body for setting the quota of an account.
+type Quota struct {
+	Quota int64
+}
+
+// AddressAdd is the request body for adding an address to an account.
+type AddressAdd struct {
+	Address string // Email address, or "@domain" for a catchall address.
+}
+
+// Error is returned for failed requests.
+type Error struct {
+	// "badRequest", "unauthorized", "notFound", "conflict", "idempotencyMismatch" or
+	// "server".
+	Code    string
+	Message string
+}
+
+func (e Error) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// Status codes for error codes.
+var errorStatus = map[string]int{
+	"badRequest":          http.StatusBadRequest,
+	"unauthorized":        http.StatusUnauthorized,
+	"notFound":            http.StatusNotFound,
+	"conflict":            http.StatusConflict,
+	"idempotencyMismatch": http.StatusUnprocessableEntity,
+	"server":              http.StatusInternalServerError,
+}
+
+// Mutating requests are handled one at a time, so idempotency keys cannot race.
+var changeMutex sync.Mutex
+
+// Handler returns the handler for the provisioning API, for requests with paths
+// starting with "/v0/".
+func Handler(isForwarded bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(isForwarded, w, r)
+	})
+}
+
+func handle(isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), mlog.CidKey, mox.Cid())
+	log := pkglog.WithContext(ctx)
+
+	if mox.Conf.Static.ProvisioningAPI == nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	writeJSON := func(status int, v any) []byte {
+		buf, err := json.Marshal(v)
+		if err != nil {
+			log.Errorx("marshal response", err)
+			status = http.StatusInternalServerError
+			buf, _ = json.Marshal(Error{"server", "marshal response"})
+		}
+		buf = append(buf, '\n')
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write(buf)
+		return buf
+	}
+
+	remoteIP := webauth.RemoteIP(log, isForwarded, r)
+	if remoteIP == nil {
+		writeJSON(http.StatusInternalServerError, Error{"server", "cannot find remote ip"})
+		return
+	}
+	t0 := time.Now()
+	if !mox.LimiterFailedAuth.CanAdd(remoteIP, t0, 1) {
+		log.Debug("refusing request due to many auth failures", slog.Any("remoteip", remoteIP))
+		http.Error(w, "429 - too many auth attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	var pt store.ProvisioningToken
+	var err error
+	if ok {
+		pt, err = store.ProvisioningTokenCheck(ctx, token)
+	}
+	if !ok || err != nil {
+		if err != nil && !errors.Is(err, store.ErrProvisioningToken) {
+			log.Errorx("checking provisioning token", err)
+			writeJSON(http.StatusInternalServerError, Error{"server", "checking token"})
+			return
+		}
+		mox.LimiterFailedAuth.Add(remoteIP, t0, 1)
+		store.AuditAdd(ctx, log, store.AuditEvent{Kind: store.AuditLogin, Protocol: "provisioning", RemoteIP: remoteIP.String(), Action: "bearer", Result: string(store.AuthBadCredentials)})
+		w.Header().Set("WWW-Authenticate", `Bearer realm="provisioning"`)
+		writeJSON(http.StatusUnauthorized, Error{"unauthorized", "missing or unknown bearer token"})
+		return
+	}
+	mox.LimiterFailedAuth.Reset(remoteIP, t0)
+	log = log.With(slog.String("token", pt.Name))
+
+	if r.Method == "GET" {
+		status, resp, _ := serve(ctx, log, r.Method, r.URL.Path, nil)
+		writeJSON(status, resp)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 1024*1024))
+	if err != nil {
+		writeJSON(http.StatusBadRequest, Error{"badRequest", "reading request body: " + err.Error()})
+		return
+	}
+
+	changeMutex.Lock()
+	defer changeMutex.Unlock()
+
+	idemKey := r.Header.Get("Idempotency-Key")
+	var idem store.ProvisioningIdempotency
+	if idemKey != "" {
+		bodyHash := sha256.Sum256(body)
+		idem = store.ProvisioningIdempotency{
+			Key:     pt.Name + "\n" + idemKey,
+			Request: fmt.Sprintf("%s %s %x", r.Method, r.URL.Path, bodyHash[:]),
+		}
+		prev := store.ProvisioningIdempotency{Key: idem.Key}
+		err := store.AuthDB.Get(ctx, &prev)
+		if err == nil && time.Since(prev.Created) < 24*time.Hour {
+			if prev.Request != idem.Request {
+				writeJSON(errorStatus["idempotencyMismatch"], Error{"idempotencyMismatch", "idempotency key was used for another request"})
+				return
+			}
+			log.Debug("repeated request with idempotency key", slog.String("key", idemKey))
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(prev.StatusCode)
+			w.Write(prev.Response)
+			return
+		} else if err == nil {
+			err = store.AuthDB.Delete(ctx, &prev)
+		}
+		if err != nil && err != bstore.ErrAbsent {
+			log.Errorx("looking up idempotency key", err)
+			writeJSON(http.StatusInternalServerError, Error{"server", "looking up idempotency key"})
+			return
+		}
+	}
+
+	status, resp, event := serve(ctx, log, r.Method, r.URL.Path, body)
+	buf := writeJSON(status, resp)
+
+	result := "ok"
+	var account string
+	if a, ok := resp.(Account); ok {
+		account = a.Name
+	} else if e, ok := resp.(Error); ok {
+		result = e.Message
+	}
+	store.AuditAdd(ctx, log, store.AuditEvent{
+		Kind:     store.AuditAccount,
+		Account:  account,
+		Actor:    "(provisioning:" + pt.Name + ")",
+		Protocol: "provisioning",
+		RemoteIP: remoteIP.String(),
+		Action:   r.Method + " " + r.URL.Path,
+		Result:   result,
+	})
+	if event != "" {
+		notify(log, event, resp.(Account))
+	}
+
+	// Only responses of requests that were handled are stored. Server errors can be
+	// retried.
+	if idemKey != "" && status < 500 {
+		idem.StatusCode = status
+		idem.Response = buf
+		err := store.AuthDB.Insert(ctx, &idem)
+		log.Check(err, "storing response for idempotency key")
+	}
+}
+
+// serve handles the request, returning the status code and a response to write
+// as JSON, an Error for failed requests. For successful changes, the event for
+// the notification is returned.
+func serve(ctx context.Context, log mlog.Log, method, path string, body []byte) (status int, resp any, event string) {
+	xerror := func(code, format string, args ...any) (int, any, string) {
+		err := Error{code, fmt.Sprintf(format, args...)}
+		if code == "server" {
+			log.Errorx("provisioning request", err)
+		} else {
+			log.Debugx("provisioning request", err)
+		}
+		return errorStatus[code], err, ""
+	}
+
+	parse := func(v any) error {
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	}
+
+	// Errors from the admin package for bad requests are user errors.
+	xadmin := func(err error, msg string) (int, any, string) {
+		if errors.Is(err, admin.ErrRequest) || errors.Is(err, mox.ErrConfig) {
+			return xerror("badRequest", "%s: %v", msg, err)
+		}
+		return xerror("server", "%s: %v", msg, err)
+	}
+
+	t := strings.Split(strings.TrimPrefix(path, "/v0/"), "/")
+	if !strings.HasPrefix(path, "/v0/") || t[0] != "accounts" {
+		return xerror("notFound", "unknown endpoint")
+	}
+	for i := range t {
+		s, err := url.PathUnescape(t[i])
+		if err != nil {
+			return xerror("badRequest", "bad path: %v", err)
+		}
+		t[i] = s
+	}
+
+	if len(t) == 1 {
+		switch method {
+		case "GET":
+			l := []Account{}
+			for _, name := range slices.Sorted(slices.Values(mox.Conf.Accounts())) {
+				a, err := accountGet(ctx, log, name)
+				if err != nil {
+					return xerror("server", "get account %q: %v", name, err)
+				}
+				l = append(l, a)
+			}
+			return http.StatusOK, l, ""
+
+		case "POST":
+			var ac AccountCreate
+			if err := parse(&ac); err != nil {
+				return xerror("badRequest", "parsing request: %v", err)
+			}
+			if ac.Name == "" {
+				return xerror("badRequest", "missing account name")
+			}
+			if _, ok := mox.Conf.Account(ac.Name); ok {
+				return xerror("conflict", "account already exists")
+			}
+			if err := admin.AccountAdd(ctx, ac.Name, ac.Address); err != nil {
+				return xadmin(err, "adding account")
+			}
+			if ac.Quota != 0 {
+				if err := admin.AccountSave(ctx, ac.Name, func(acc *config.Account) { acc.QuotaMessageSize = ac.Quota }); err != nil {
+					return xadmin(err, "setting quota")
+				}
+			}
+			if ac.Password != "" {
+				if err := passwordSet(log, ac.Name, ac.Password); err != nil {
+					return xerror("server", "setting password: %v", err)
+				}
+			}
+			a, err := accountGet(ctx, log, ac.Name)
+			if err != nil {
+				return xerror("server", "get account: %v", err)
+			}
+			return http.StatusCreated, a, EventAccountCreated
+		}
+		return xerror("badRequest", "method not allowed")
+	}
+
+	name := t[1]
+	if _, ok := mox.Conf.Account(name); !ok {
+		return xerror("notFound", "account does not exist")
+	}
+	var op string
+	if len(t) >= 3 {
+		op = t[2]
+	}
+
+	switch {
+	case len(t) == 2 && method == "GET":
+
+	case len(t) == 2 && method == "DELETE":
+		a, err := accountGet(ctx, log, name)
+		if err != nil {
+			return xerror("server", "get account: %v", err)
+		}
+		if err := admin.AccountRemove(ctx, name); err != nil {
+			return xadmin(err, "removing account")
+		}
+		a.State = StateDeleted
+		return http.StatusOK, a, EventAccountDeleted
+
+	case len(t) == 3 && op == "suspend" && method == "POST":
+		var s Suspend
+		if err := parse(&s); err != nil {
+			return xerror("badRequest", "parsing request: %v", err)
+		}
+		if s.Message == "" {
+			s.Message = "account suspended"
+		}
+		if err := loginDisabledSave(ctx, log, name, s.Message); err != nil {
+			return xadmin(err, "suspending account")
+		}
+		event = EventAccountSuspended
+
+	case len(t) == 3 && op == "activate" && method == "POST":
+		if err := loginDisabledSave(ctx, log, name, ""); err != nil {
+			return xadmin(err, "activating account")
+		}
+		event = EventAccountActivated
+
+	case len(t) == 3 && op == "quota" && method == "PUT":
+		var q Quota
+		if err := parse(&q); err != nil {
+			return xerror("badRequest", "parsing request: %v", err)
+		}
+		if err := admin.AccountSave(ctx, name, func(acc *config.Account) { acc.QuotaMessageSize = q.Quota }); err != nil {
+			return xadmin(err, "setting quota")
+		}
+		event = EventAccountQuota
+
+	case len(t) == 3 && op == "addresses" && method == "POST":
+		var aa AddressAdd
+		if err := parse(&aa); err != nil {
+			return xerror("badRequest", "parsing request: %v", err)
+		}
+		if err := admin.AddressAdd(ctx, aa.Address, name); err != nil {
+			return xadmin(err, "adding address")
+		}
+		event = EventAddressAdded
+
+	case len(t) == 4 && op == "addresses" && method == "DELETE":
+		conf, _ := mox.Conf.Account(name)
+		if _, ok := conf.Destinations[t[3]]; !ok {
+			return xerror("notFound", "address does not belong to account")
+		}
+		if err := admin.AddressRemove(ctx, t[3]); err != nil {
+			return xadmin(err, "removing address")
+		}
+		event = EventAddressRemoved
+
+	default:
+		return xerror("notFound", "unknown endpoint or method")
+	}
+
+	a, err := accountGet(ctx, log, name)
+	if err != nil {
+		return xerror("server", "get account: %v", err)
+	}
+	return http.StatusOK, a, event
+}
+
+// accountGet returns the account for the API.
+func accountGet(ctx context.Context, log mlog.Log, name string) (Account, error) {
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		return Account{}, err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	conf, _ := acc.Conf()
+	a := Account{
+		Name:           name,
+		State:          StateActive,
+		SuspendMessage: conf.LoginDisabled,
+		Addresses:      slices.Sorted(maps.Keys(conf.Destinations)),
+		Quota:          conf.QuotaMessageSize,
+	}
+	if conf.LoginDisabled != "" {
+		a.State = StateSuspended
+	}
+	err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		du := store.DiskUsage{ID: 1}
+		err := tx.Get(&du)
+		a.DiskUsage = du.MessageSize
+		return err
+	})
+	return a, err
+}
+
+func passwordSet(log mlog.Log, name, password string) error {
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	return acc.SetPassword(log, password)
+}
+
+// loginDisabledSave suspends (non-empty message) or activates an account. Sessions
+// are removed when suspending.
+func loginDisabledSave(ctx context.Context, log mlog.Log, name, message string) error {
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	if err := admin.AccountSave(ctx, name, func(acc *config.Account) { acc.LoginDisabled = message }); err != nil {
+		return err
+	}
+	if message != "" {
+		return acc.SessionsClear(ctx, log)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package provisioning
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	gotbuf, _ := json.Marshal(got)
+	expbuf, _ := json.Marshal(exp)
+	if !bytes.Equal(gotbuf, expbuf) {
+		t.Fatalf("got:\n%s\nexpected:\n%s", gotbuf, expbuf)
+	}
+}
+
+func TestProvisioning(t *testing.T) {
+	// Accounts are added and removed in domains.conf, so work on a copy.
+	dir := t.TempDir()
+	for _, name := range []string{"mox.conf", "domains.conf"} {
+		buf, err := os.ReadFile(filepath.FromSlash("../testdata/store/" + name))
+		tcheck(t, err, "read config")
+		err = os.WriteFile(filepath.Join(dir, name), buf, 0660)
+		tcheck(t, err, "write config")
+	}
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	mox.MustLoadConfig(true, false)
+	mox.LimitersInit()
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	err = queue.Init()
+	tcheck(t, err, "queue init")
+	defer queue.Shutdown()
+
+	notifications := make(chan Notification, 10)
+	hooksrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var n Notification
+		err := json.NewDecoder(r.Body).Decode(&n)
+		tcheck(t, err, "parse notification")
+		notifications <- n
+	}))
+	defer hooksrv.Close()
+	notifyDelays = []time.Duration{0}
+
+	mox.Conf.Static.ProvisioningAPI = &config.ProvisioningAPI{WebhookURL: hooksrv.URL}
+	defer func() {
+		mox.Conf.Static.ProvisioningAPI = nil
+	}()
+
+	token, err := store.ProvisioningTokenAdd(ctxbg, "panel")
+	tcheck(t, err, "add token")
+	_, err = store.ProvisioningTokenAdd(ctxbg, "panel")
+	if err == nil {
+		t.Fatalf("adding token with duplicate name succeeded")
+	}
+
+	handler := Handler(false)
+
+	call := func(method, path, authToken, idemKey string, body any, expStatus int, resp any) http.Header {
+		t.Helper()
+		var reqBody io.Reader
+		if body != nil {
+			buf, err := json.Marshal(body)
+			tcheck(t, err, "marshal request")
+			reqBody = bytes.NewReader(buf)
+		}
+		r := httptest.NewRequest(method, path, reqBody)
+		if authToken != "" {
+			r.Header.Set("Authorization", "Bearer "+authToken)
+		}
+		if idemKey != "" {
+			r.Header.Set("Idempotency-Key", idemKey)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != expStatus {
+			t.Fatalf("%s %s: got status %d, expected %d, body %s", method, path, w.Code, expStatus, w.Body.String())
+		}
+		if resp != nil {
+			err := json.Unmarshal(w.Body.Bytes(), resp)
+			tcheck(t, err, "parse response")
+		}
+		return w.Result().Header
+	}
+
+	expectNotification := func(event, account, state string) {
+		t.Helper()
+		select {
+		case n := <-notifications:
+			tcompare(t, n.Event, event)
+			tcompare(t, n.Account.Name, account)
+			tcompare(t, n.Account.State, state)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("no notification for %s", event)
+		}
+	}
+
+	var apiErr Error
+	call("GET", "/v0/accounts", "", "", nil, http.StatusUnauthorized, &apiErr)
+	tcompare(t, apiErr.Code, "unauthorized")
+	call("GET", "/v0/accounts", "bogus", "", nil, http.StatusUnauthorized, nil)
+
+	var accounts []Account
+	call("GET", "/v0/accounts", token, "", nil, http.StatusOK, &accounts)
+	tcompare(t, len(accounts), 1)
+	tcompare(t, accounts[0].State, StateActive)
+
+	var a Account
+	create := AccountCreate{Name: "new", Address: "new@mox.example", Password: "test1234", Quota: 1024 * 1024}
+	call("POST", "/v0/accounts", token, "create1", create, http.StatusCreated, &a)
+	tcompare(t, a, Account{Name: "new", State: StateActive, Addresses: []string{"new@mox.example"}, Quota: 1024 * 1024})
+	expectNotification(EventAccountCreated, "new", StateActive)
+
+	// Repeated request with same idempotency key gets same response, without change.
+	h := call("POST", "/v0/accounts", token, "create1", create, http.StatusCreated, &a)
+	tcompare(t, h.Get("Idempotent-Replayed"), "true")
+	tcompare(t, a.Name, "new")
+	// Same key for different request is an error.
+	call("POST", "/v0/accounts", token, "create1", AccountCreate{Name: "other", Address: "other2@mox.example"}, http.StatusUnprocessableEntity, &apiErr)
+	tcompare(t, apiErr.Code, "idempotencyMismatch")
+	// Without key, the account already exists.
+	call("POST", "/v0/accounts", token, "", create, http.StatusConflict, nil)
+	call("POST", "/v0/accounts", token, "", AccountCreate{Address: "x@mox.example"}, http.StatusBadRequest, nil)
+
+	call("GET", "/v0/accounts/new", token, "", nil, http.StatusOK, &a)
+	tcompare(t, a.Name, "new")
+	call("GET", "/v0/accounts/bogus", token, "", nil, http.StatusNotFound, nil)
+	call("GET", "/v0/bogus", token, "", nil, http.StatusNotFound, nil)
+
+	call("POST", "/v0/accounts/new/suspend", token, "", Suspend{Message: "unpaid"}, http.StatusOK, &a)
+	tcompare(t, a.State, StateSuspended)
+	tcompare(t, a.SuspendMessage, "unpaid")
+	expectNotification(EventAccountSuspended, "new", StateSuspended)
+
+	call("POST", "/v0/accounts/new/activate", token, "", nil, http.StatusOK, &a)
+	tcompare(t, a.State, StateActive)
+	expectNotification(EventAccountActivated, "new", StateActive)
+
+	call("PUT", "/v0/accounts/new/quota", token, "", Quota{Quota: 2048}, http.StatusOK, &a)
+	tcompare(t, a.Quota, int64(2048))
+	expectNotification(EventAccountQuota, "new", StateActive)
+
+	call("POST", "/v0/accounts/new/addresses", token, "", AddressAdd{Address: "new2@mox.example"}, http.StatusOK, &a)
+	tcompare(t, a.Addresses, []string{"new2@mox.example", "new@mox.example"})
+	expectNotification(EventAddressAdded, "new", StateActive)
+
+	call("DELETE", "/v0/accounts/new/addresses/mjl@mox.example", token, "", nil, http.StatusNotFound, nil)
+	call("DELETE", "/v0/accounts/new/addresses/new2@mox.example", token, "", nil, http.StatusOK, &a)
+	tcompare(t, a.Addresses, []string{"new@mox.example"})
+	expectNotification(EventAddressRemoved, "new", StateActive)
+
+	call("DELETE", "/v0/accounts/new", token, "", nil, http.StatusOK, &a)
+	tcompare(t, a.State, StateDeleted)
+	expectNotification(EventAccountDeleted, "new", StateDeleted)
+	call("GET", "/v0/accounts/new", token, "", nil, http.StatusNotFound, nil)
+
+	// Changes are in the audit log.
+	l, err := store.AuditList(ctxbg, store.AuditFilter{Actor: "(provisioning:panel)"}, 0)
+	tcheck(t, err, "list audit events")
+	if len(l) == 0 {
+		t.Fatalf("no audit events for provisioning api")
+	}
+
+	tokens, err := store.ProvisioningTokenList(ctxbg)
+	tcheck(t, err, "list tokens")
+	tcompare(t, len(tokens), 1)
+	if tokens[0].LastUsed.IsZero() {
+		t.Fatalf("last use of token not set")
+	}
+	err = store.ProvisioningTokenRemove(ctxbg, "panel")
+	tcheck(t, err, "remove token")
+	call("GET", "/v0/accounts", token, "", nil, http.StatusUnauthorized, nil)
+}
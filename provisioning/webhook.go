@@ -0,0 +1,86 @@
+package provisioning
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/queue"
+)
+
+// Events in notifications.
+const (
+	EventAccountCreated   = "account.created"
+	EventAccountDeleted   = "account.deleted"
+	EventAccountSuspended = "account.suspended"
+	EventAccountActivated = "account.activated"
+	EventAccountQuota     = "account.quota"
+	EventAddressAdded     = "address.added"
+	EventAddressRemoved   = "address.removed"
+)
+
+// Notification is sent to the configured webhook URL after a change.
+type Notification struct {
+	ID      int64 // Sequence number since start of mox, also in header X-Mox-Webhook-ID.
+	Event   string
+	Time    time.Time
+	Account Account // Account after the change.
+}
+
+var notificationID atomic.Int64
+
+// Delays before attempts to deliver a notification. Modified during tests.
+var notifyDelays = []time.Duration{0, time.Minute, 5 * time.Minute, 30 * time.Minute}
+
+// notify sends a notification for the event in the background, if configured.
+func notify(log mlog.Log, event string, a Account) {
+	pc := mox.Conf.Static.ProvisioningAPI
+	if pc == nil || pc.WebhookURL == "" {
+		return
+	}
+
+	n := Notification{notificationID.Add(1), event, time.Now(), a}
+	buf, err := json.Marshal(n)
+	if err != nil {
+		log.Errorx("marshal provisioning notification", err)
+		return
+	}
+	payload := string(buf)
+	log = log.With(slog.Int64("notificationid", n.ID), slog.String("event", event))
+
+	go func() {
+		defer func() {
+			x := recover()
+			if x != nil {
+				log.Error("unhandled panic while sending provisioning notification", slog.Any("err", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Provisioning)
+			}
+		}()
+
+		for i, d := range notifyDelays {
+			select {
+			case <-mox.Shutdown.Done():
+				log.Info("provisioning notification not sent due to shutdown")
+				return
+			case <-time.After(d):
+			}
+
+			ctx, cancel := context.WithTimeout(mox.Shutdown, 30*time.Second)
+			_, _, err := queue.HookPost(ctx, log, n.ID, i+1, pc.WebhookURL, pc.WebhookAuthorization, pc.WebhookSigningKey, payload)
+			cancel()
+			if err == nil {
+				log.Debug("provisioning notification sent")
+				return
+			}
+			log.Infox("sending provisioning notification", err, slog.Int("attempt", i+1))
+		}
+		log.Error("provisioning notification not sent after all attempts")
+	}()
+}
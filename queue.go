@@ -90,6 +90,37 @@ func ctlcmdQueueHoldrulesRemove(ctl *ctl, id int64) {
 	ctl.xreadok()
 }
 
+func cmdQueueHoldrulesResume(c *cmd) {
+	c.params = "ruleid"
+	c.help = `Remove hold rule and resume delivery of matching messages.
+
+Remove a hold rule by its id, like "queue holdrules remove", and additionally
+take messages in the queue that match the rule off hold. Messages that also
+match another hold rule remain on hold, as do messages put on hold explicitly
+with "queue hold". Messages are delivered according to
+their current next delivery attempt, see "queue schedule".
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	xcheckf(err, "parsing id")
+	mustLoadConfig()
+	ctlcmdQueueHoldrulesResume(xctl(), id)
+}
+
+func ctlcmdQueueHoldrulesResume(ctl *ctl, id int64) {
+	ctl.xwrite("queueholdrulesresume")
+	ctl.xwrite(fmt.Sprintf("%d", id))
+	line := ctl.xread()
+	if line == "ok" {
+		fmt.Printf("%s messages changed\n", ctl.xread())
+	} else {
+		log.Fatalf("%s", line)
+	}
+}
+
 // flagFilterSort is used by many of the queue commands to accept flags for
 // filtering the messages the operation applies to.
 func flagFilterSort(fs *flag.FlagSet, f *queue.Filter, s *queue.Sort) {
@@ -109,6 +140,8 @@ func flagFilterSort(fs *flag.FlagSet, f *queue.Filter, s *queue.Sort) {
 	fs.StringVar(&f.To, "to", "", `recipient address of message, use "@example.com" to match all messages for a domain`)
 	fs.StringVar(&f.Submitted, "submitted", "", `filter by time of submission relative to now, value must start with "<" (before now) or ">" (after now)`)
 	fs.StringVar(&f.NextAttempt, "nextattempt", "", `filter by time of next delivery attempt relative to now, value must start with "<" (before now) or ">" (after now)`)
+	fs.StringVar(&f.Domain, "domain", "", `recipient domain of message, use ".example.com" to also match subdomains`)
+	fs.StringVar(&f.LastError, "lasterror", "", "text in error of last delivery attempt, case-insensitive")
 	fs.Func("transport", "transport to use for messages, empty string sets the default behaviour", func(v string) error {
 		f.Transport = &v
 		return nil
@@ -602,6 +635,164 @@ func ctlcmdQueueRetiredPrint(ctl *ctl, id string) {
 	}
 }
 
+// flagDeadFilter is used by the dead-letter queue commands to accept flags for
+// filtering the messages the operation applies to.
+func flagDeadFilter(fs *flag.FlagSet, f *queue.DeadFilter) {
+	fs.Func("ids", "comma-separated list of dead-letter message IDs", func(v string) error {
+		for _, s := range strings.Split(v, ",") {
+			id, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				return err
+			}
+			f.IDs = append(f.IDs, id)
+		}
+		return nil
+	})
+	fs.IntVar(&f.Max, "n", 0, "number of messages to return")
+	fs.StringVar(&f.Account, "account", "", "account that queued the message")
+	fs.StringVar(&f.From, "from", "", `from address of message, use "@example.com" to match all messages for a domain`)
+	fs.StringVar(&f.To, "to", "", `recipient address of message, use "@example.com" to match all messages for a domain`)
+}
+
+func cmdQueueDeadList(c *cmd) {
+	c.params = "[filterflags]"
+	c.help = `List matching messages in the dead-letter queue.
+
+Messages for which delivery failed permanently are kept in the dead-letter queue
+if QueueDeadLetterPeriod is configured in mox.conf. Prints messages with their
+ID, time of failure and last error.
+`
+	var f queue.DeadFilter
+	flagDeadFilter(c.flag, &f)
+	if len(c.Parse()) != 0 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdQueueDeadList(xctl(), f)
+}
+
+func ctlcmdQueueDeadList(ctl *ctl, f queue.DeadFilter) {
+	ctl.xwrite("queuedeadlist")
+	xctlwriteJSON(ctl, f)
+	ctl.xreadok()
+	if _, err := io.Copy(os.Stdout, ctl.reader()); err != nil {
+		log.Fatalf("%s", err)
+	}
+}
+
+func cmdQueueDeadPrint(c *cmd) {
+	c.params = "id"
+	c.help = `Print a message from the dead-letter queue.
+
+Prints a JSON representation of the information from the dead-letter queue,
+including the results of all delivery attempts.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdQueueDeadPrint(xctl(), args[0])
+}
+
+func ctlcmdQueueDeadPrint(ctl *ctl, id string) {
+	ctl.xwrite("queuedeadprint")
+	ctl.xwrite(id)
+	ctl.xreadok()
+	if _, err := io.Copy(os.Stdout, ctl.reader()); err != nil {
+		log.Fatalf("%s", err)
+	}
+}
+
+func cmdQueueDeadDump(c *cmd) {
+	c.params = "id"
+	c.help = `Dump a message from the dead-letter queue.
+
+The message is printed to stdout and is in standard internet mail format.
+`
+	args := c.Parse()
+	if len(args) != 1 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdQueueDeadDump(xctl(), args[0])
+}
+
+func ctlcmdQueueDeadDump(ctl *ctl, id string) {
+	ctl.xwrite("queuedeaddump")
+	ctl.xwrite(id)
+	ctl.xreadok()
+	if _, err := io.Copy(os.Stdout, ctl.reader()); err != nil {
+		log.Fatalf("%s", err)
+	}
+}
+
+func cmdQueueDeadRecipient(c *cmd) {
+	c.params = "id address"
+	c.help = `Change the recipient of a message in the dead-letter queue.
+
+Useful for fixing a mistyped recipient address before requeueing the message.
+Only the SMTP RCPT TO address is changed, not the message headers.
+`
+	args := c.Parse()
+	if len(args) != 2 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdQueueDeadRecipient(xctl(), args[0], args[1])
+}
+
+func ctlcmdQueueDeadRecipient(ctl *ctl, id, address string) {
+	ctl.xwrite("queuedeadrecipient")
+	ctl.xwrite(id)
+	ctl.xwrite(address)
+	ctl.xreadok()
+}
+
+func cmdQueueDeadRequeue(c *cmd) {
+	c.params = "[filterflags]"
+	c.help = `Requeue matching messages from the dead-letter queue.
+
+Matching messages are added to the queue as new messages, with new IDs, for
+immediate delivery with a fresh set of delivery attempts, and removed from the
+dead-letter queue.
+`
+	var f queue.DeadFilter
+	flagDeadFilter(c.flag, &f)
+	if len(c.Parse()) != 0 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdQueueDeadCount(xctl(), "queuedeadrequeue", f)
+}
+
+func cmdQueueDeadRemove(c *cmd) {
+	c.params = "[filterflags]"
+	c.help = `Remove matching messages from the dead-letter queue.
+
+Messages are removed from the dead-letter queue automatically after the
+configured QueueDeadLetterPeriod.
+`
+	var f queue.DeadFilter
+	flagDeadFilter(c.flag, &f)
+	if len(c.Parse()) != 0 {
+		c.Usage()
+	}
+	mustLoadConfig()
+	ctlcmdQueueDeadCount(xctl(), "queuedeadremove", f)
+}
+
+func ctlcmdQueueDeadCount(ctl *ctl, cmd string, f queue.DeadFilter) {
+	ctl.xwrite(cmd)
+	xctlwriteJSON(ctl, f)
+	line := ctl.xread()
+	if line == "ok" {
+		fmt.Printf("%s messages changed\n", ctl.xread())
+	} else {
+		log.Fatalf("%s", line)
+	}
+}
+
 // note: outgoing hook events are in queue/hooks.go, mox-/config.go, queue.go and webapi/gendoc.sh. keep in sync.
 
 // flagHookFilterSort is used by many of the queue commands to accept flags for
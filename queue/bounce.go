@@ -0,0 +1,161 @@
+package queue
+
+import (
+	"bufio"
+	"io"
+	"net/textproto"
+	"strings"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dsn"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/webhook"
+)
+
+// Phrases in SMTP responses from remote servers that indicate our server is
+// blocked, instead of the recipient address being the problem.
+var bounceBlockPhrases = []string{
+	"blocklist",
+	"blacklist",
+	"block list",
+	"black list",
+	"blocked",
+	"spamhaus",
+	"spamcop",
+	"barracuda",
+	"reputation",
+}
+
+// Phrases that indicate the recipient address does not exist.
+var bounceHardPhrases = []string{
+	"user unknown",
+	"unknown user",
+	"no such user",
+	"no such mailbox",
+	"does not exist",
+	"doesn't exist",
+	"invalid recipient",
+	"recipient not found",
+	"account disabled",
+}
+
+// Phrases that indicate a temporary condition for the recipient.
+var bounceSoftPhrases = []string{
+	"mailbox full",
+	"over quota",
+	"quota exceeded",
+	"insufficient storage",
+	"try again later",
+}
+
+func bounceContains(text string, phrases []string) bool {
+	for _, p := range phrases {
+		if strings.Contains(text, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// bounceClassify classifies a delivery failure based on the SMTP code, the short
+// enhanced status code (without class, e.g. "1.1") and the lines of the SMTP
+// response or DSN diagnostic code. Empty is returned if code is zero.
+func bounceClassify(code int, secode string, lines []string) webhook.BounceClass {
+	if code == 0 {
+		return ""
+	}
+	text := strings.ToLower(strings.Join(lines, " "))
+
+	// A blocked server is not specific to the recipient, and is typically mentioned
+	// in the text only, both for temporary and permanent errors.
+	if bounceContains(text, bounceBlockPhrases) {
+		return webhook.BounceBlock
+	}
+	if code/100 == 4 {
+		return webhook.BounceSoft
+	}
+
+	// Enhanced status codes are the most specific.
+	subject, _, _ := strings.Cut(secode, ".")
+	switch secode {
+	case smtp.SeAddr1UnknownDestMailbox1, smtp.SeAddr1UnknownSystem2, smtp.SeAddr1MailboxSyntax3, smtp.SeAddr1DestMailboxMoved6, smtp.SeAddr1NullMX, smtp.SeMailbox2Disabled1:
+		return webhook.BounceHard
+	case smtp.SeMailbox2Full2, smtp.SeSys3StorageFull1:
+		return webhook.BounceSoft
+	case smtp.SeMailbox2MsgLimitExceeded3, smtp.SeSys3MsgLimitExceeded4:
+		return webhook.BouncePolicy
+	case smtp.SePol7DeliveryUnauth1:
+		// Often used for blocklisted senders, which we would have recognized above.
+		// Otherwise typically an explicit rejection of the recipient.
+		if bounceContains(text, bounceHardPhrases) {
+			return webhook.BounceHard
+		}
+		return webhook.BouncePolicy
+	}
+	switch subject {
+	case "1":
+		return webhook.BounceHard
+	case "2", "3", "4", "5":
+		// Mailbox, mail system, network/routing and protocol issues are not caused by the
+		// recipient address not existing. Unless the text says otherwise.
+		if bounceContains(text, bounceHardPhrases) {
+			return webhook.BounceHard
+		}
+		return webhook.BounceSoft
+	case "6", "7":
+		return webhook.BouncePolicy
+	}
+
+	// No (useful) enhanced status code, look at the text and basic code.
+	if bounceContains(text, bounceHardPhrases) {
+		return webhook.BounceHard
+	} else if bounceContains(text, bounceSoftPhrases) {
+		return webhook.BounceSoft
+	}
+	switch code {
+	case smtp.C521HostNoMail, smtp.C550MailboxUnavail, smtp.C551UserNotLocal, smtp.C553BadMailbox, smtp.C556DomainNoMail:
+		return webhook.BounceHard
+	case smtp.C552MailboxFull:
+		return webhook.BounceSoft
+	}
+	return webhook.BouncePolicy
+}
+
+// dsnRetiredMsg looks up the retired message an incoming DSN is about, for DSNs
+// sent to an address without fromid. The message-id of the original message,
+// from the third part of the DSN, and the recipient must match. Returns
+// bstore.ErrAbsent if no message is found.
+func dsnRetiredMsg(log mlog.Log, tx *bstore.Tx, account string, part message.Part) (MsgRetired, error) {
+	if len(part.Parts) < 3 {
+		return MsgRetired{}, bstore.ErrAbsent
+	}
+	dsnmsg, err := dsn.Decode(part.Parts[1].ReaderUTF8OrBinary(), part.Parts[1].MediaSubType == "GLOBAL-DELIVERY-STATUS")
+	if err != nil {
+		log.Debugx("parsing dsn for matching original message", err)
+		return MsgRetired{}, bstore.ErrAbsent
+	} else if len(dsnmsg.Recipients) != 1 {
+		return MsgRetired{}, bstore.ErrAbsent
+	}
+
+	// The third part is either the original message or only its headers. We add a
+	// header separator in case the part only contains headers.
+	r := io.MultiReader(io.LimitReader(part.Parts[2].Reader(), 64*1024), strings.NewReader("\r\n\r\n"))
+	h, err := textproto.NewReader(bufio.NewReader(r)).ReadMIMEHeader()
+	if err != nil {
+		log.Debugx("parsing headers of original message in dsn", err)
+		return MsgRetired{}, bstore.ErrAbsent
+	}
+	messageID := strings.TrimSpace(h.Get("Message-Id"))
+	if messageID == "" {
+		return MsgRetired{}, bstore.ErrAbsent
+	}
+
+	q := bstore.QueryTx[MsgRetired](tx)
+	q.FilterNonzero(MsgRetired{SenderAccount: account, MessageID: messageID, RecipientAddress: dsnmsg.Recipients[0].FinalRecipient.XString(true)})
+	q.SortDesc("LastActivity")
+	q.Limit(1)
+	return q.Get()
+}
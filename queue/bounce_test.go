@@ -0,0 +1,37 @@
+package queue
+
+import (
+	"testing"
+
+	"github.com/mjl-/mox/webhook"
+)
+
+func TestBounceClassify(t *testing.T) {
+	test := func(code int, secode string, line string, exp webhook.BounceClass) {
+		t.Helper()
+		var lines []string
+		if line != "" {
+			lines = []string{line}
+		}
+		tcompare(t, bounceClassify(code, secode, lines), exp)
+	}
+
+	test(0, "", "", "")
+	test(550, "1.1", "550 5.1.1 no such user", webhook.BounceHard)
+	test(550, "1.10", "550 5.1.10 null mx", webhook.BounceHard)
+	test(552, "2.2", "552 5.2.2 mailbox full", webhook.BounceSoft)
+	test(452, "2.2", "452 4.2.2 over quota", webhook.BounceSoft)
+	test(451, "7.1", "451 4.7.1 greylisted", webhook.BounceSoft)
+	test(554, "7.1", "554 5.7.1 service unavailable; client host blocked using zen.spamhaus.org", webhook.BounceBlock)
+	test(421, "", "421 ip address temporarily blocked due to reputation", webhook.BounceBlock)
+	test(550, "7.1", "550 5.7.1 recipient not found", webhook.BounceHard)
+	test(550, "7.1", "550 5.7.1 rejected by policy", webhook.BouncePolicy)
+	test(550, "7.26", "550 5.7.26 unauthenticated email is not accepted", webhook.BouncePolicy)
+	test(554, "6.0", "554 5.6.0 content rejected", webhook.BouncePolicy)
+	test(554, "4.4", "554 5.4.4 unable to route", webhook.BounceSoft)
+	test(550, "", "550 user unknown", webhook.BounceHard)
+	test(550, "", "550 no", webhook.BounceHard)
+	test(552, "", "552 message too large", webhook.BounceSoft)
+	test(554, "", "554 transaction failed", webhook.BouncePolicy)
+	test(554, "0.0", "554 5.0.0 mailbox full", webhook.BounceSoft)
+}
@@ -0,0 +1,168 @@
+package queue
+
+import (
+	"io"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+// After a delivery attempt, SMTP connections are kept open for a short while, so a
+// delivery of another message to the same host, with the same TLS requirements,
+// can reuse the connection. This saves the TCP, TLS and EHLO exchanges (and
+// authentication for submission) for bursts of messages to the same destination.
+// A connection is only used by a single delivery at a time.
+
+var metricConnectionReuse = promauto.NewCounter(
+	prometheus.CounterOpts{
+		Name: "mox_queue_connection_reuse_total",
+		Help: "Queue client connections reused for another delivery.",
+	},
+)
+
+// How long an idle connection is kept for reuse. Var for tests.
+var connPoolIdle = 30 * time.Second
+
+const (
+	connPoolMaxIdle = 4   // Per key, additional connections are closed.
+	connPoolMaxUses = 100 // Deliveries over a connection, many servers limit transactions per connection.
+)
+
+// connKey identifies connections that can be reused for a delivery. All
+// parameters that influenced the connection setup are part of the key.
+type connKey struct {
+	transportName string
+	transport     any // Transport config, pointer. After a config reload, connections aren't reused.
+	ehloHostname  string
+	host          string
+	port          int
+	tlsMode       smtpclient.TLSMode
+	tlsPKIX       bool
+	tlsVerify     string // For direct delivery: DANE records, allowed host names, whether verification errors are ignored.
+}
+
+// pooledConn is a connection with an initialized SMTP session.
+type pooledConn struct {
+	client   *smtpclient.Client // Nil if SMTP session was not initialized.
+	conn     net.Conn           // Underlying connection, registered with mox.Connections.
+	remoteIP net.IP
+	uses     int
+	timer    *time.Timer
+}
+
+var connPool = struct {
+	sync.Mutex
+	idle map[connKey][]*pooledConn
+}{idle: map[connKey][]*pooledConn{}}
+
+// close closes the SMTP session and underlying connection.
+func (pc *pooledConn) close(log mlog.Log) {
+	if pc.client != nil {
+		err := pc.client.Close()
+		log.Check(err, "closing smtp connection")
+	} else {
+		err := pc.conn.Close()
+		log.Check(err, "closing smtp tcp connection")
+	}
+	mox.Connections.Unregister(pc.conn)
+}
+
+// connPoolGet returns an idle connection for key, with the SMTP transaction state
+// reset. Connections for which the reset fails, e.g. because the remote server
+// closed the connection, are closed. Nil is returned if no connection can be
+// reused.
+func connPoolGet(log mlog.Log, key connKey) *pooledConn {
+	for {
+		connPool.Lock()
+		l := connPool.idle[key]
+		if len(l) == 0 {
+			connPool.Unlock()
+			return nil
+		}
+		pc := l[len(l)-1]
+		if len(l) == 1 {
+			delete(connPool.idle, key)
+		} else {
+			connPool.idle[key] = l[:len(l)-1]
+		}
+		connPool.Unlock()
+		// If the timer already fired, its function won't find the connection in the pool
+		// and leaves it to us.
+		pc.timer.Stop()
+
+		if err := pc.client.Reset(); err != nil {
+			log.Debugx("resetting idle smtp connection, closing", err, slog.String("host", key.host), slog.Any("remoteip", pc.remoteIP))
+			pc.close(log)
+			continue
+		}
+		metricConnectionReuse.Inc()
+		log.Debug("reusing smtp connection", slog.String("host", key.host), slog.Any("remoteip", pc.remoteIP), slog.Int("uses", pc.uses))
+		return pc
+	}
+}
+
+// connPoolPut adds a connection to the pool after a delivery attempt, or closes it
+// if it cannot be reused.
+func connPoolPut(log mlog.Log, key connKey, pc *pooledConn) {
+	pc.uses++
+	if pc.client == nil || pc.client.Botched() || pc.uses >= connPoolMaxUses || connPoolIdle <= 0 || mox.Shutdown.Err() != nil {
+		pc.close(log)
+		return
+	}
+	// The transcript buffer belongs to the delivery attempt that just finished.
+	pc.client.SetTranscript(io.Discard)
+
+	connPool.Lock()
+	defer connPool.Unlock()
+	if len(connPool.idle[key]) >= connPoolMaxIdle {
+		pc.close(log)
+		return
+	}
+	pc.timer = time.AfterFunc(connPoolIdle, func() {
+		connPool.Lock()
+		l := connPool.idle[key]
+		i := -1
+		for j, xpc := range l {
+			if xpc == pc {
+				i = j
+				break
+			}
+		}
+		if i < 0 {
+			// Taken from the pool for reuse.
+			connPool.Unlock()
+			return
+		}
+		if len(l) == 1 {
+			delete(connPool.idle, key)
+		} else {
+			connPool.idle[key] = append(l[:i:i], l[i+1:]...)
+		}
+		connPool.Unlock()
+		pc.close(log)
+	})
+	connPool.idle[key] = append(connPool.idle[key], pc)
+}
+
+// connPoolCloseAll closes all idle connections, e.g. during shutdown.
+func connPoolCloseAll(log mlog.Log) {
+	connPool.Lock()
+	idle := connPool.idle
+	connPool.idle = map[connKey][]*pooledConn{}
+	connPool.Unlock()
+
+	for _, l := range idle {
+		for _, pc := range l {
+			pc.timer.Stop()
+			pc.close(log)
+		}
+	}
+}
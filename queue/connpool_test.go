@@ -0,0 +1,117 @@
+package queue
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+func TestConnPool(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	defer func(d time.Duration) {
+		connPoolIdle = d
+	}(connPoolIdle)
+
+	// Fake SMTP server, counting RSET commands.
+	rsets := make(chan struct{}, 10)
+	serve := func(conn net.Conn) {
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		fmt.Fprintf(conn, "220 mox.example\r\n")
+		for {
+			line, err := br.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				fmt.Fprintf(conn, "250 mox.example\r\n")
+			case cmd == "RSET":
+				rsets <- struct{}{}
+				fmt.Fprintf(conn, "250 ok\r\n")
+			case cmd == "QUIT":
+				fmt.Fprintf(conn, "221 bye\r\n")
+				return
+			default:
+				fmt.Fprintf(conn, "500 unknown\r\n")
+			}
+		}
+	}
+	connect := func() *pooledConn {
+		t.Helper()
+		client, server := net.Pipe()
+		go serve(server)
+		sc, err := smtpclient.New(ctxbg, pkglog.Logger, client, smtpclient.TLSSkip, false, dns.Domain{ASCII: "localhost"}, dns.Domain{ASCII: "mx.example"}, smtpclient.Opts{})
+		tcheck(t, err, "new smtp client")
+		return &pooledConn{client: sc, conn: client}
+	}
+	count := func() (n int) {
+		connPool.Lock()
+		defer connPool.Unlock()
+		for _, l := range connPool.idle {
+			n += len(l)
+		}
+		return n
+	}
+
+	key := connKey{host: "mx.example", port: 25, tlsMode: smtpclient.TLSSkip}
+	otherKey := key
+	otherKey.tlsPKIX = true
+
+	// Nothing in pool yet.
+	tcompare(t, connPoolGet(pkglog, key) == nil, true)
+
+	// Put connection in pool, only retrieved with same key, with RSET.
+	pc := connect()
+	connPoolPut(pkglog, key, pc)
+	tcompare(t, count(), 1)
+	tcompare(t, connPoolGet(pkglog, otherKey) == nil, true)
+	xpc := connPoolGet(pkglog, key)
+	tcompare(t, xpc, pc)
+	<-rsets
+	tcompare(t, pc.uses, 1)
+	tcompare(t, count(), 0)
+
+	// Limit on idle connections per key.
+	pcs := []*pooledConn{xpc}
+	for range connPoolMaxIdle {
+		pcs = append(pcs, connect())
+	}
+	for _, pc := range pcs {
+		connPoolPut(pkglog, key, pc)
+	}
+	tcompare(t, count(), connPoolMaxIdle)
+	tcompare(t, pcs[len(pcs)-1].client.Botched(), true) // Closed.
+
+	// Closed after idle timeout.
+	connPoolCloseAll(pkglog)
+	tcompare(t, count(), 0)
+	connPoolIdle = 10 * time.Millisecond
+	pc = connect()
+	connPoolPut(pkglog, key, pc)
+	ctx, cancel := context.WithTimeout(ctxbg, time.Second)
+	defer cancel()
+	for count() > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	tcompare(t, count(), 0)
+	tcompare(t, connPoolGet(pkglog, key) == nil, true)
+
+	// Broken connection is not reused.
+	connPoolIdle = time.Minute
+	pc = connect()
+	connPoolPut(pkglog, key, pc)
+	err := pc.conn.Close()
+	tcheck(t, err, "close connection")
+	tcompare(t, connPoolGet(pkglog, key) == nil, true)
+	tcompare(t, count(), 0)
+}
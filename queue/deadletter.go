@@ -0,0 +1,361 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// MsgDead is a message for which delivery failed permanently, kept in the
+// dead-letter queue along with its message file, if configured with
+// QueueDeadLetterPeriod in mox.conf. The admin can inspect the delivery results,
+// export the message, change the recipient and requeue the message for delivery.
+type MsgDead struct {
+	ID int64 // Same ID as it was as Msg.ID.
+
+	Queued             time.Time
+	SenderAccount      string
+	SenderLocalpart    smtp.Localpart
+	SenderDomain       dns.IPDomain
+	SenderDomainStr    string // For filtering, unicode.
+	FromID             string
+	RecipientLocalpart smtp.Localpart
+	RecipientDomain    dns.IPDomain
+	RecipientDomainStr string // For filtering, unicode.
+	Attempts           int
+	MaxAttempts        int
+	DialedIPs          map[string][]net.IP
+	LastAttempt        *time.Time
+	Results            []MsgResult // Results of all delivery attempts, the last one being the permanent failure.
+
+	Has8bit              bool
+	SMTPUTF8             bool
+	Size                 int64
+	MessageID            string
+	MsgPrefix            []byte
+	Subject              string
+	Transport            string
+	RequireTLS           *bool
+	FutureReleaseRequest string
+	Priority             int
+	Extra                map[string]string
+
+	Failed    time.Time `bstore:"index"` // Time the message was moved to the dead-letter queue.
+	KeepUntil time.Time `bstore:"index"`
+}
+
+// Sender of message as used in MAIL FROM.
+func (m MsgDead) Sender() smtp.Path {
+	return smtp.Path{Localpart: m.SenderLocalpart, IPDomain: m.SenderDomain}
+}
+
+// Recipient of message as used in RCPT TO.
+func (m MsgDead) Recipient() smtp.Path {
+	return smtp.Path{Localpart: m.RecipientLocalpart, IPDomain: m.RecipientDomain}
+}
+
+// LastResult returns the last result entry, or an empty result.
+func (m MsgDead) LastResult() MsgResult {
+	if len(m.Results) == 0 {
+		return MsgResult{}
+	}
+	return m.Results[len(m.Results)-1]
+}
+
+// MessagePath returns the path where the message is stored.
+func (m MsgDead) MessagePath() string {
+	return mox.DataDirPath(filepath.Join("queue", "dead", store.MessagePath(m.ID)))
+}
+
+// Dead returns a MsgDead for the message, for keeping it in the dead-letter queue.
+func (m Msg) Dead(t, keepUntil time.Time) MsgDead {
+	return MsgDead{
+		ID:                   m.ID,
+		Queued:               m.Queued,
+		SenderAccount:        m.SenderAccount,
+		SenderLocalpart:      m.SenderLocalpart,
+		SenderDomain:         m.SenderDomain,
+		SenderDomainStr:      m.SenderDomainStr,
+		FromID:               m.FromID,
+		RecipientLocalpart:   m.RecipientLocalpart,
+		RecipientDomain:      m.RecipientDomain,
+		RecipientDomainStr:   m.RecipientDomainStr,
+		Attempts:             m.Attempts,
+		MaxAttempts:          m.MaxAttempts,
+		DialedIPs:            m.DialedIPs,
+		LastAttempt:          m.LastAttempt,
+		Results:              m.Results,
+		Has8bit:              m.Has8bit,
+		SMTPUTF8:             m.SMTPUTF8,
+		Size:                 m.Size,
+		MessageID:            m.MessageID,
+		MsgPrefix:            m.MsgPrefix,
+		Subject:              m.Subject,
+		Transport:            m.Transport,
+		RequireTLS:           m.RequireTLS,
+		FutureReleaseRequest: m.FutureReleaseRequest,
+		Priority:             m.Priority,
+		Extra:                m.Extra,
+
+		Failed:    t,
+		KeepUntil: keepUntil,
+	}
+}
+
+// deadLetterMsgs adds permanently failed messages to the dead-letter queue if
+// configured. Returns the messages that were kept, for which the caller must move
+// the message files with deadLetterMsgsFS after committing the transaction, and
+// the messages that were not kept, for which the caller must still remove the
+// message files. DMARC and TLS reports are never kept.
+func deadLetterMsgs(tx *bstore.Tx, msgs []Msg) (dead, remaining []Msg, rerr error) {
+	period := mox.Conf.Static.QueueDeadLetterPeriod
+	if period <= 0 {
+		return nil, msgs, nil
+	}
+
+	now := time.Now()
+	for _, m := range msgs {
+		if m.IsDMARCReport || m.IsTLSReport {
+			remaining = append(remaining, m)
+			continue
+		}
+		dm := m.Dead(now, now.Add(period))
+		if err := tx.Insert(&dm); err != nil {
+			return nil, nil, fmt.Errorf("inserting message in dead-letter queue: %v", err)
+		}
+		dead = append(dead, m)
+	}
+	return dead, remaining, nil
+}
+
+// deadLetterMsgsFS moves the message files of messages added to the dead-letter
+// queue by deadLetterMsgs, after committing the transaction.
+func deadLetterMsgsFS(log mlog.Log, msgs ...Msg) {
+	for _, m := range msgs {
+		dst := MsgDead{ID: m.ID}.MessagePath()
+		os.MkdirAll(filepath.Dir(dst), 0770)
+		if err := os.Rename(m.MessagePath(), dst); err != nil {
+			log.Errorx("moving message file to dead-letter queue", err, slog.Int64("msgid", m.ID))
+			continue
+		}
+		log.Info("moved message to dead-letter queue", slog.Int64("msgid", m.ID), slog.Any("recipient", m.Recipient()))
+	}
+}
+
+// DeadFilter filters messages in the dead-letter queue to list or operate on.
+//
+// Only non-empty/non-zero values are applied to the filter. Leaving all fields
+// empty/zero matches all messages.
+type DeadFilter struct {
+	Max     int
+	IDs     []int64
+	Account string
+	From    string
+	To      string
+}
+
+func (f DeadFilter) apply(q *bstore.Query[MsgDead]) error {
+	if len(f.IDs) > 0 {
+		q.FilterIDs(f.IDs)
+	}
+	if f.Account != "" {
+		q.FilterNonzero(MsgDead{SenderAccount: f.Account})
+	}
+	if f.From != "" {
+		q.FilterFn(func(m MsgDead) bool {
+			return strings.Contains(m.Sender().XString(true), f.From)
+		})
+	}
+	if f.To != "" {
+		q.FilterFn(func(m MsgDead) bool {
+			return strings.Contains(m.Recipient().XString(true), f.To)
+		})
+	}
+	if f.Max != 0 {
+		q.Limit(f.Max)
+	}
+	return nil
+}
+
+// DeadList returns messages in the dead-letter queue, most recently failed first.
+func DeadList(ctx context.Context, filter DeadFilter) ([]MsgDead, error) {
+	q := bstore.QueryDB[MsgDead](ctx, DB)
+	if err := filter.apply(q); err != nil {
+		return nil, err
+	}
+	q.SortDesc("Failed", "ID")
+	return q.List()
+}
+
+// OpenDeadMessage opens a message in the dead-letter queue, for exporting.
+func OpenDeadMessage(ctx context.Context, id int64) (ReadReaderAtCloser, error) {
+	dm := MsgDead{ID: id}
+	if err := DB.Get(ctx, &dm); err != nil {
+		return nil, err
+	}
+	f, err := os.Open(dm.MessagePath())
+	if err != nil {
+		return nil, fmt.Errorf("open message file: %s", err)
+	}
+	return store.FileMsgReader(dm.MsgPrefix, f), nil
+}
+
+// DeadRecipientSet changes the recipient of a message in the dead-letter queue,
+// e.g. to fix a mistyped recipient domain before requeueing.
+func DeadRecipientSet(ctx context.Context, log mlog.Log, id int64, rcpt smtp.Path) error {
+	if rcpt.Localpart == "" || rcpt.IPDomain.IsZero() {
+		return fmt.Errorf("recipient address must be non-empty")
+	}
+	return DB.Write(ctx, func(tx *bstore.Tx) error {
+		dm := MsgDead{ID: id}
+		if err := tx.Get(&dm); err != nil {
+			return err
+		}
+		log.Info("changing recipient of message in dead-letter queue", slog.Int64("msgid", id), slog.Any("oldrecipient", dm.Recipient()), slog.Any("newrecipient", rcpt))
+		dm.RecipientLocalpart = rcpt.Localpart
+		dm.RecipientDomain = rcpt.IPDomain
+		dm.RecipientDomainStr = formatIPDomain(rcpt.IPDomain)
+		return tx.Update(&dm)
+	})
+}
+
+// DeadRequeue adds matching messages from the dead-letter queue back to the
+// regular queue as new messages, for immediate delivery with a fresh set of
+// delivery attempts. Any retired record of the original message is removed, so
+// its FromID can be reused. Returns the number of requeued messages, which can
+// be non-zero even in case of an error.
+func DeadRequeue(ctx context.Context, log mlog.Log, filter DeadFilter) (affected int, err error) {
+	l, err := DeadList(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for _, dm := range l {
+		if err := deadRequeue(ctx, log, dm); err != nil {
+			return affected, fmt.Errorf("requeueing message %d: %w", dm.ID, err)
+		}
+		affected++
+	}
+	return affected, nil
+}
+
+func deadRequeue(ctx context.Context, log mlog.Log, dm MsgDead) error {
+	f, err := os.Open(dm.MessagePath())
+	if err != nil {
+		return fmt.Errorf("open message file: %v", err)
+	}
+	defer func() {
+		err := f.Close()
+		log.Check(err, "closing message file from dead-letter queue")
+	}()
+
+	qm := Msg{
+		Queued:               time.Now(),
+		SenderLocalpart:      dm.SenderLocalpart,
+		SenderDomain:         dm.SenderDomain,
+		FromID:               dm.FromID,
+		RecipientLocalpart:   dm.RecipientLocalpart,
+		RecipientDomain:      dm.RecipientDomain,
+		MaxAttempts:          dm.MaxAttempts,
+		NextAttempt:          time.Now(),
+		Results:              dm.Results,
+		Has8bit:              dm.Has8bit,
+		SMTPUTF8:             dm.SMTPUTF8,
+		Size:                 dm.Size,
+		MessageID:            dm.MessageID,
+		MsgPrefix:            dm.MsgPrefix,
+		Subject:              dm.Subject,
+		Transport:            dm.Transport,
+		RequireTLS:           dm.RequireTLS,
+		FutureReleaseRequest: dm.FutureReleaseRequest,
+		Priority:             dm.Priority,
+		Extra:                dm.Extra,
+	}
+
+	// Adding to the queue and removing from the dead-letter queue happen in a single
+	// transaction, so a message is never in both queues, or in neither.
+	var paths []string
+	err = DB.Write(ctx, func(tx *bstore.Tx) error {
+		err := tx.Delete(&MsgRetired{ID: dm.ID})
+		if err != nil && !errors.Is(err, bstore.ErrAbsent) {
+			return fmt.Errorf("removing retired message: %v", err)
+		}
+		if err := tx.Delete(&MsgDead{ID: dm.ID}); err != nil {
+			return fmt.Errorf("removing message from dead-letter queue: %v", err)
+		}
+		paths, err = addTx(log, tx, dm.SenderAccount, f, qm)
+		return err
+	})
+	if err != nil {
+		removeAddedFiles(log, paths)
+		return err
+	}
+	msgqueueKick()
+	log.Info("requeued message from dead-letter queue", slog.Int64("msgid", dm.ID), slog.Any("recipient", dm.Recipient()))
+
+	deadRemoveFS(log, dm)
+	return nil
+}
+
+// DeadRemove removes matching messages from the dead-letter queue.
+func DeadRemove(ctx context.Context, log mlog.Log, filter DeadFilter) (affected int, err error) {
+	l, err := DeadList(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	for _, dm := range l {
+		if err := deadRemove(ctx, log, dm); err != nil {
+			return affected, err
+		}
+		affected++
+	}
+	return affected, nil
+}
+
+func deadRemove(ctx context.Context, log mlog.Log, dm MsgDead) error {
+	if err := DB.Delete(ctx, &dm); err != nil {
+		return fmt.Errorf("removing message from dead-letter queue: %v", err)
+	}
+	deadRemoveFS(log, dm)
+	return nil
+}
+
+// deadRemoveFS removes the message file of a message removed from the dead-letter
+// queue, after committing the transaction.
+func deadRemoveFS(log mlog.Log, dm MsgDead) {
+	p := dm.MessagePath()
+	if err := os.Remove(p); err != nil {
+		log.Errorx("removing message file from dead-letter queue", err, slog.String("path", p))
+	} else if err := moxio.SyncDir(log, filepath.Dir(p)); err != nil {
+		log.Errorx("sync directory after removing message file from dead-letter queue", err)
+	}
+}
+
+func cleanupMsgDeadSingle(log mlog.Log) {
+	l, err := bstore.QueryDB[MsgDead](mox.Shutdown, DB).FilterLess("KeepUntil", time.Now()).List()
+	if err != nil {
+		log.Errorx("listing expired messages in dead-letter queue", err)
+		return
+	}
+	for _, dm := range l {
+		err := deadRemove(mox.Shutdown, log, dm)
+		log.Check(err, "removing expired message from dead-letter queue")
+	}
+	if len(l) > 0 {
+		log.Debug("cleaned up dead-letter queue", slog.Int("count", len(l)))
+	}
+}
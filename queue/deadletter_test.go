@@ -0,0 +1,147 @@
+package queue
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/dsn"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+func TestDeadLetter(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	mox.Conf.Static.QueueDeadLetterPeriod = time.Hour
+	defer func() {
+		mox.Conf.Static.QueueDeadLetterPeriod = 0
+	}()
+
+	sender := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}
+	rcpt := smtp.Path{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "typo.example"}}}
+	mf := prepareFile(t)
+	defer os.Remove(mf.Name())
+	defer mf.Close()
+
+	fail := func(qm Msg) {
+		t.Helper()
+		now := time.Now()
+		qm.Attempts = 1
+		qm.LastAttempt = &now
+		failMsgsDB(pkglog, []*Msg{&qm}, nil, time.Minute, dsn.NameIP{}, smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Err: errors.New("no such user")})
+	}
+
+	qm := MakeMsg(sender, rcpt, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	qm.FromID = "fromid1"
+	err := Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message to queue")
+	msgs, err := List(ctxbg, Filter{}, Sort{})
+	tcheck(t, err, "list queue")
+	tcompare(t, len(msgs), 1)
+	qm = msgs[0]
+
+	// Permanent failure moves the message to the dead-letter queue.
+	fail(qm)
+	n, err := Count(ctxbg)
+	tcheck(t, err, "count queue")
+	tcompare(t, n, 0)
+
+	l, err := DeadList(ctxbg, DeadFilter{})
+	tcheck(t, err, "list dead-letter queue")
+	tcompare(t, len(l), 1)
+	dm := l[0]
+	tcompare(t, dm.ID, qm.ID)
+	tcompare(t, dm.Recipient().String(), "remote@typo.example")
+	tcompare(t, dm.LastResult().Code, smtp.C550MailboxUnavail)
+
+	// From and To must both match.
+	l, err = DeadList(ctxbg, DeadFilter{From: "mjl@", To: "other@"})
+	tcheck(t, err, "list dead-letter queue")
+	tcompare(t, len(l), 0)
+	l, err = DeadList(ctxbg, DeadFilter{From: "mjl@", To: "remote@"})
+	tcheck(t, err, "list dead-letter queue")
+	tcompare(t, len(l), 1)
+
+	// Export message.
+	mr, err := OpenDeadMessage(ctxbg, dm.ID)
+	tcheck(t, err, "open dead-letter message")
+	buf, err := io.ReadAll(mr)
+	tcheck(t, err, "read dead-letter message")
+	mr.Close()
+	tcompare(t, string(buf), testmsg)
+
+	// Fix recipient and requeue.
+	fixed := smtp.Path{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "remote.example"}}}
+	err = DeadRecipientSet(ctxbg, pkglog, dm.ID, fixed)
+	tcheck(t, err, "set recipient")
+	err = DeadRecipientSet(ctxbg, pkglog, dm.ID, smtp.Path{})
+	if err == nil {
+		t.Fatalf("setting empty recipient succeeded")
+	}
+
+	// Failure to add to the queue, due to a duplicate FromID, keeps the message in
+	// the dead-letter queue.
+	qm = MakeMsg(sender, rcpt, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	qm.FromID = "fromid1"
+	err = Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message with same fromid to queue")
+	n, err = DeadRequeue(ctxbg, pkglog, DeadFilter{IDs: []int64{dm.ID}})
+	if !errors.Is(err, ErrFromID) {
+		t.Fatalf("requeue with duplicate fromid, got err %v, expected %v", err, ErrFromID)
+	}
+	tcompare(t, n, 0)
+	l, err = DeadList(ctxbg, DeadFilter{})
+	tcheck(t, err, "list dead-letter queue")
+	tcompare(t, len(l), 1)
+	if _, err := os.Stat(dm.MessagePath()); err != nil {
+		t.Fatalf("dead-letter message file gone after failed requeue: %v", err)
+	}
+	n, err = Drop(ctxbg, pkglog, Filter{})
+	tcheck(t, err, "drop message with same fromid")
+	tcompare(t, n, 1)
+
+	n, err = DeadRequeue(ctxbg, pkglog, DeadFilter{IDs: []int64{dm.ID}})
+	tcheck(t, err, "requeue")
+	tcompare(t, n, 1)
+	l, err = DeadList(ctxbg, DeadFilter{})
+	tcheck(t, err, "list dead-letter queue")
+	tcompare(t, len(l), 0)
+	if _, err := os.Stat(dm.MessagePath()); err == nil {
+		t.Fatalf("dead-letter message file still present after requeue")
+	}
+
+	msgs, err = List(ctxbg, Filter{}, Sort{})
+	tcheck(t, err, "list queue")
+	tcompare(t, len(msgs), 1)
+	tcompare(t, msgs[0].Recipient().String(), "remote@remote.example")
+	tcompare(t, msgs[0].Attempts, 0)
+	if msgs[0].ID == dm.ID {
+		t.Fatalf("requeued message did not get new id")
+	}
+
+	// Reports are never kept.
+	qm = msgs[0]
+	qm.IsDMARCReport = true
+	fail(qm)
+	l, err = DeadList(ctxbg, DeadFilter{})
+	tcheck(t, err, "list dead-letter queue")
+	tcompare(t, len(l), 0)
+
+	// Remove and cleanup.
+	qm = MakeMsg(sender, rcpt, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	err = Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message to queue")
+	msgs, err = List(ctxbg, Filter{}, Sort{})
+	tcheck(t, err, "list queue")
+	qm = msgs[0]
+	fail(qm)
+	n, err = DeadRemove(ctxbg, pkglog, DeadFilter{Account: "mjl"})
+	tcheck(t, err, "remove from dead-letter queue")
+	tcompare(t, n, 1)
+}
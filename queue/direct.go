@@ -127,11 +127,19 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 	//   - If RequireTLS is false, we'll fall back to regular delivery attempts without
 	//     TLS verification and possibly without TLS at all, ignoring recipient domain/host
 	//     MTA-STS and DANE policies.
+	//   - A TLS policy configured for the recipient domain can require STARTTLS (with
+	//     or without verification, or with pinned certificates), or allow fallback like
+	//     RequireTLS false.
 
 	// For convenience, we use m0 to access properties that are shared over all
 	// messages we are delivering.
 	m0 := msgs[0]
 
+	// Record DNS lookups with their DNSSEC status, for showing the security
+	// guarantees of the delivery attempt.
+	recorder := dns.NewRecorder(resolver)
+	resolver = recorder
+
 	// Resolve domain and hosts to attempt delivery to.
 	// These next-hop names are often the name under which we find MX records. The
 	// expanded name is different from the original if the original was a CNAME,
@@ -139,7 +147,7 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 	// directly.
 	origNextHop := m0.RecipientDomain.Domain
 	ctx := mox.Shutdown
-	haveMX, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, permanent, err := smtpclient.GatherDestinations(ctx, qlog.Logger, resolver, m0.RecipientDomain)
+	haveMX, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, hostPrefs, permanent, err := smtpclient.GatherDestinations(ctx, qlog.Logger, resolver, m0.RecipientDomain)
 	if err != nil {
 		// If this is a DNSSEC authentication error, we'll collect it for TLS reporting.
 		// Hopefully it's a temporary misconfiguration that is solve before we try to send
@@ -160,7 +168,11 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 		return
 	}
 
-	tlsRequiredNo := m0.RequireTLS != nil && !*m0.RequireTLS
+	// Within the same MX preference, prefer hosts without recent delivery failures.
+	hosts = hostHealthOrder(ctx, qlog, hosts, hostPrefs)
+
+	tlsPolicy := findTLSPolicy(*m0)
+	tlsRequiredNo := msgTLSRequiredNo(*m0, tlsPolicy)
 
 	// Check for MTA-STS policy and enforce it if needed.
 	// We must check at the original next-hop, i.e. recipient domain, not following any
@@ -238,6 +250,10 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 			tlsPKIX = true
 			// note: smtpclient will still go through PKIX verification, and report about it, but not fail the connection if not passing.
 		}
+		if tlsPolicyRequired(tlsPolicy) {
+			tlsMode = smtpclient.TLSRequiredStartTLS
+			tlsPKIX = tlsPKIX || tlsPolicy.Mode == "verify"
+		}
 
 		// Try to deliver to host. We can get various errors back. Like permanent failure
 		// response codes, TCP, DNSSEC, TLS (opportunistic, i.e. optional with fallback to
@@ -255,6 +271,15 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 		}
 
 		result := deliverHost(nqlog, resolver, dialer, ourHostname, transportName, transportDirect, h, enforceMTASTS, haveMX, origNextHopAuthentic, origNextHop, expandedNextHopAuthentic, expandedNextHop, msgResps, tlsMode, tlsPKIX, &recipientDomainResult)
+		hostHealthRecord(nqlog, h, result)
+		if tlsPolicyRequired(tlsPolicy) && errors.Is(result.err, smtpclient.ErrTLS) {
+			reason := "tls"
+			if errors.Is(result.err, errTLSPolicyCertificate) {
+				reason = "certificate"
+			}
+			metricTLSPolicyViolation.WithLabelValues(reason).Inc()
+			nqlog.Infox("tls policy for recipient domain not satisfied", result.err, slog.Any("host", h), slog.String("reason", reason))
+		}
 
 		var zerotype tlsrpt.PolicyType
 		if result.hostResult.Policy.Type != zerotype {
@@ -276,15 +301,19 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 				metricTLSRequiredNoIgnored.WithLabelValues("badtls").Inc()
 			}
 
-			// todo future: add a configuration option to not fall back?
 			nqlog.Info("connecting again for delivery attempt without tls",
 				slog.Bool("enforcemtasts", enforceMTASTS),
 				slog.Bool("tlsdane", result.tlsDANE),
 				slog.Any("requiretls", m0.RequireTLS))
 			result = deliverHost(nqlog, resolver, dialer, ourHostname, transportName, transportDirect, h, enforceMTASTS, haveMX, origNextHopAuthentic, origNextHop, expandedNextHopAuthentic, expandedNextHop, msgResps, smtpclient.TLSSkip, false, &tlsrpt.Result{})
+			hostHealthRecord(nqlog, h, result)
 		}
 
 		remoteMTA = dsn.NameIP{Name: h.XString(false), IP: remoteIP}
+		result.session.DNSLookups = recorder.Lookups()
+		for _, mr := range msgResps {
+			mr.msg.markSession(result.session)
+		}
 		if result.err != nil {
 			lastErr = result.err
 			var cerr smtpclient.Error
@@ -303,7 +332,7 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 		for i, mr := range result.delivered {
 			mqlog := nqlog.With(slog.Int64("msgid", mr.msg.ID), slog.Any("recipient", mr.msg.Recipient()))
 			mqlog.Info("delivered from queue")
-			mr.msg.markResult(mr.resp.Code, mr.resp.Secode, "", true)
+			mr.msg.markResult(mr.resp.Code, mr.resp.Secode, responseLines(mr.resp.Line, mr.resp.MoreLines), "", true)
 			delMsgs[i] = *mr.msg
 		}
 		if len(delMsgs) > 0 {
@@ -318,13 +347,18 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 			kick()
 		}
 		if len(result.failed) > 0 {
+			var remove, dead []Msg
 			err := DB.Write(context.Background(), func(tx *bstore.Tx) error {
 				for _, mr := range result.failed {
-					failMsgsTx(nqlog, tx, []*Msg{mr.msg}, m0.DialedIPs, backoff, remoteMTA, smtpclient.Error(mr.resp))
+					xremove, xdead := failMsgsTx(nqlog, tx, []*Msg{mr.msg}, m0.DialedIPs, backoff, remoteMTA, smtpclient.Error(mr.resp))
+					remove = append(remove, xremove...)
+					dead = append(dead, xdead...)
 				}
 				return nil
 			})
-			if err != nil {
+			if err == nil {
+				failMsgsFS(nqlog, remove, dead)
+			} else {
 				for _, mr := range result.failed {
 					nqlog.Errorx("error processing delivery failure for messages", err,
 						slog.Int64("msgid", mr.msg.ID),
@@ -365,9 +399,11 @@ func deliverDirect(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 }
 
 type deliverResult struct {
-	tlsDANE    bool
-	remoteIP   net.IP
-	hostResult tlsrpt.Result
+	tlsDANE       bool
+	remoteIP      net.IP
+	connectFailed bool // Looking up IPs or connecting failed, for host health.
+	hostResult    tlsrpt.Result
+	session       MsgResult // Details about SMTP session, for storing in delivery results.
 
 	// If err is set, no messages were delivered but delivered and failed are still
 	// nil. If err is not set, delivered and always add up to all msgs requested to be
@@ -401,14 +437,17 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 	// About attempting delivery to multiple addresses of a host: ../rfc/5321:3898
 
 	m0 := msgResps[0].msg
-	tlsRequiredNo := m0.RequireTLS != nil && !*m0.RequireTLS
+	tlsPolicy := findTLSPolicy(*m0)
+	tlsRequiredNo := msgTLSRequiredNo(*m0, tlsPolicy)
 
 	var tlsDANE bool
+	var daneStatus string // For MsgResult.DANEStatus.
 	var remoteIP net.IP
 	var hostResult tlsrpt.Result
 	start := time.Now()
 	defer func() {
 		result.tlsDANE = tlsDANE
+		result.session.DANEStatus = daneStatus
 		result.remoteIP = remoteIP
 		result.hostResult = hostResult
 
@@ -432,6 +471,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 			slog.Any("tlsmode", tlsMode),
 			slog.Bool("tlspkix", tlsPKIX),
 			slog.Bool("tlsdane", tlsDANE),
+			slog.String("danestatus", daneStatus),
 			slog.Bool("tlsrequiredno", tlsRequiredNo),
 			slog.Bool("badtls", result.err != nil && errors.Is(result.err, smtpclient.ErrTLS)),
 			slog.Duration("duration", time.Since(start)))
@@ -473,11 +513,18 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 
 	metricDestinations.Inc()
 	network := "ip"
+	dialPolicy := smtpclient.DialPolicy{HappyEyeballsDelay: smtpclient.DefaultHappyEyeballsDelay}
 	if transportDirect != nil {
 		if network != transportDirect.IPFamily {
 			log.Debug("set custom IP network family for direct transport", slog.Any("network", transportDirect.IPFamily))
 			network = transportDirect.IPFamily
 		}
+		dialPolicy.PreferIPFamily = transportDirect.PreferIPFamily
+		if transportDirect.DisableHappyEyeballs {
+			dialPolicy.HappyEyeballsDelay = 0
+		} else if transportDirect.HappyEyeballsDelay > 0 {
+			dialPolicy.HappyEyeballsDelay = transportDirect.HappyEyeballsDelay
+		}
 	}
 	authentic, expandedAuthentic, expandedHost, ips, dualstack, err := smtpclient.GatherIPs(ctx, log.Logger, resolver, network, host, m0.DialedIPs)
 	destAuthentic := err == nil && authentic && origNextHopAuthentic && (!haveMX || expandedNextHopAuthentic) && host.IsDomain()
@@ -485,9 +532,11 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		log.Debugx("not attempting verification with dane", err, slog.Bool("authentic", authentic), slog.Bool("expandedauthentic", expandedAuthentic))
 
 		// Track a DNSSEC error if found.
+		daneStatus = "insecure"
 		var errCode adns.ErrorCode
 		if err != nil {
 			if errors.As(err, &errCode) && errCode.IsAuthentication() {
+				daneStatus = "dnssec-error"
 				// Result: ../rfc/8460:567
 				reasonCode := fmt.Sprintf("dns-extended-error-%d-%s", errCode, strings.ReplaceAll(errCode.String(), " ", "-"))
 				fd := tlsrpt.Details(tlsrpt.ResultValidationFailure, reasonCode)
@@ -503,6 +552,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 
 		// TLSSkip is used to fallback to plaintext, which is used with a TLS-Required: No
 		// header to ignore the recipient domain's DANE policy.
+		daneStatus = "skipped"
 
 		// possible err is propagated to below.
 	} else {
@@ -519,6 +569,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 			metricDestinationDANEGatherTLSAErrors.Inc()
 		}
 		if err == nil && tlsDANE {
+			daneStatus = "required"
 			tlsMode = smtpclient.TLSRequiredStartTLS
 			hostResult = tlsrpt.Result{Policy: tlsrpt.TLSAPolicy(daneRecords, tlsaBaseDomain)}
 			if len(daneRecords) == 0 {
@@ -530,6 +581,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 				log.Debug("no usable dane records, requiring starttls but not verifying with dane")
 				metricDestinationDANESTARTTLSUnverified.Inc()
 				daneRecords = nil
+				daneStatus = "unusable"
 				// Result: ../rfc/8460:576 (this isn't technicall invalid, only all-unusable...)
 				hostResult.FailureDetails = []tlsrpt.FailureDetails{
 					{
@@ -547,8 +599,10 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		} else if !tlsDANE {
 			log.Debugx("not doing opportunistic dane after gathering tlsa records", err)
 			err = nil
+			daneStatus = "no-tlsa"
 			hostResult = tlsrpt.MakeResult(tlsrpt.NoPolicyFound, tlsaBaseDomain)
 		} else if err != nil {
+			daneStatus = "tlsa-error"
 			fd := tlsrpt.Details(tlsrpt.ResultTLSAInvalid, "")
 			var errCode adns.ErrorCode
 			if errors.As(err, &errCode) {
@@ -567,10 +621,16 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 			if tlsRequiredNo {
 				log.Debugx("error gathering dane tlsa records with dane required, but continuing without validation due to tls-required-no message header", err)
 				err = nil
+				daneStatus = "ignored"
 				metricTLSRequiredNoIgnored.WithLabelValues("badtlsa").Inc()
 			}
 		}
 		// else, err is propagated below.
+
+		if daneStatus == "required" && tlsRequiredNo {
+			// Verification errors are ignored, see opts below.
+			daneStatus = "ignored"
+		}
 	}
 
 	// todo: for requiretls, should an MTA-STS policy in mode testing be treated as good enough for requiretls? let's be strict and assume not.
@@ -588,30 +648,51 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		return deliverResult{err: smtpErr}
 	}
 
+	// An idle connection to the host that was set up with the same TLS requirements
+	// can be reused.
+	poolKey := connKey{
+		transportName: transportName,
+		transport:     transportDirect,
+		ehloHostname:  ourHostname.ASCII,
+		host:          hostHealthKey(host),
+		port:          25,
+		tlsMode:       tlsMode,
+		tlsPKIX:       tlsPKIX,
+		tlsVerify:     fmt.Sprintf("%v %v %v", daneRecords, tlsHostnames, tlsRequiredNo),
+	}
+
 	// Dial the remote host given the IPs if no error yet.
 	var conn net.Conn
+	var pc *pooledConn
 	if err == nil {
+		pc = connPoolGet(log, poolKey)
+	}
+	if pc != nil {
+		conn, remoteIP = pc.conn, pc.remoteIP
+	} else if err == nil {
 		connectionCounter.Add(1)
-		conn, remoteIP, err = smtpclient.Dial(ctx, log.Logger, dialer, host, ips, 25, m0.DialedIPs, mox.Conf.Static.SpecifiedSMTPListenIPs)
+		conn, remoteIP, err = smtpclient.Dial(ctx, log.Logger, dialer, host, ips, 25, m0.DialedIPs, mox.Conf.Static.SpecifiedSMTPListenIPs, dialPolicy)
 	}
 	cancel()
 
 	// Set error for metrics.
-	var dialResult string
-	switch {
-	case err == nil:
-		dialResult = "ok"
-	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
-		dialResult = "timeout"
-	case errors.Is(err, context.Canceled):
-		dialResult = "canceled"
-	default:
-		dialResult = "error"
+	if pc == nil {
+		var dialResult string
+		switch {
+		case err == nil:
+			dialResult = "ok"
+		case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+			dialResult = "timeout"
+		case errors.Is(err, context.Canceled):
+			dialResult = "canceled"
+		default:
+			dialResult = "error"
+		}
+		metricConnection.WithLabelValues(dialResult).Inc()
 	}
-	metricConnection.WithLabelValues(dialResult).Inc()
 	if err != nil {
 		log.Debugx("connecting to remote smtp", err, slog.Any("host", host))
-		return deliverResult{err: fmt.Errorf("dialing smtp server: %v", err)}
+		return deliverResult{err: fmt.Errorf("dialing smtp server: %v", err), connectFailed: true}
 	}
 
 	var mailFrom string
@@ -623,7 +704,6 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 	log = log.With(slog.Any("remoteip", remoteIP))
 	ctx, cancel = context.WithTimeout(mox.Shutdown, 30*time.Minute)
 	defer cancel()
-	mox.Connections.Register(conn, "smtpclient", "queue")
 
 	// Initialize SMTP session, sending EHLO/HELO and STARTTLS with specified tls mode.
 	var firstHost dns.Domain
@@ -634,6 +714,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		moreHosts = tlsHostnames[1:]
 	}
 	var verifiedRecord adns.TLSA
+	var transcript bytes.Buffer
 	opts := smtpclient.Opts{
 		IgnoreTLSVerifyErrors: tlsRequiredNo,
 		RootCAs:               mox.Conf.Static.TLS.CertPool,
@@ -642,18 +723,39 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		DANEVerifiedRecord:    &verifiedRecord,
 		RecipientDomainResult: recipientDomainResult,
 		HostResult:            &hostResult,
+		Transcript:            &transcript,
 	}
-	sc, err := smtpclient.New(ctx, log.Logger, conn, tlsMode, tlsPKIX, ourHostname, firstHost, opts)
+	var sc *smtpclient.Client
+	if pc != nil {
+		sc = pc.client
+		sc.SetTranscript(&transcript)
+	} else {
+		mox.Connections.Register(conn, "smtpclient", "queue")
+		pc = &pooledConn{conn: conn, remoteIP: remoteIP}
+		sc, err = smtpclient.New(ctx, log.Logger, conn, tlsMode, tlsPKIX, ourHostname, firstHost, opts)
+		pc.client = sc
+	}
+	sessionOK := err == nil
 	defer func() {
-		if sc == nil {
-			err := conn.Close()
-			log.Check(err, "closing smtp tcp connection")
+		// Keep the session for reuse, unless it was not set up properly or got botched.
+		if sessionOK {
+			connPoolPut(log, poolKey, pc)
 		} else {
-			err := sc.Close()
-			log.Check(err, "closing smtp connection")
+			pc.close(log)
 		}
-		mox.Connections.Unregister(conn)
 	}()
+	defer func() {
+		// With TLS-Required: No, verification errors are ignored, so we don't claim verification.
+		remoteMTA := fmt.Sprintf("%s (%s)", host.XString(false), remoteIP)
+		result.session = sessionResult(sc, remoteMTA, transcript.String(), tlsPKIX && !tlsRequiredNo, tlsDANE && len(daneRecords) > 0 && !tlsRequiredNo)
+	}()
+	if err == nil {
+		// Pinned certificates are checked for reused connections too, the policy may
+		// have changed.
+		if err = tlsPolicyCheckCertificate(sc.TLSConnectionState(), tlsPolicy); err != nil {
+			sessionOK = false
+		}
+	}
 	if err == nil && m0.SenderAccount != "" {
 		// Remember the STARTTLS and REQUIRETLS support for this recipient domain.
 		// It is used in the webmail client, to show the recipient domain security mechanisms.
@@ -681,6 +783,11 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 				log.Debugx("change error type from permanent to transient", err, slog.Any("host", host), slog.Any("secode", cerr.Secode))
 				cerr.Permanent = false
 			}
+			// Failing STARTTLS required by our TLS policy may be fixed by the remote.
+			if cerr.Permanent && tlsPolicyRequired(tlsPolicy) && errors.Is(cerr.Err, smtpclient.ErrTLS) {
+				log.Debugx("change error type from permanent to transient due to tls policy", err, slog.Any("host", host))
+				cerr.Permanent = false
+			}
 			// If server does not implement requiretls, respond with that code. ../rfc/8689:301
 			if errors.Is(cerr.Err, smtpclient.ErrRequireTLSUnsupported) {
 				cerr.Secode = smtp.SePol7MissingReqTLS30
@@ -735,6 +842,7 @@ func deliverHost(log mlog.Log, resolver dns.Resolver, dialer smtpclient.Dialer,
 		// 7-bit-only, but the trouble likely isn't worth it.
 		req8bit := has8bit && mox.Pedantic
 
+		sc.SetMTPriority(m0.Priority)
 		resps, err := sc.DeliverMultiple(ctx, mailFrom, rcpts, size, msg, req8bit, smtputf8, m0.RequireTLS != nil && *m0.RequireTLS)
 		if err != nil && (len(resps) == 0 && n == len(msgResps) || len(resps) == len(msgResps)) {
 			// If error and it applies to all recipients, return a single error.
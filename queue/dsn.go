@@ -37,13 +37,20 @@ var (
 	)
 )
 
+// ListBounce is called for permanent delivery failures of mailing list messages,
+// instead of delivering a DSN to the sender account. Set by package mlist.
+var ListBounce = func(log mlog.Log, sender, recipient smtp.Path) {}
+
 // failMsgsDB calls failMsgsTx with a new transaction, logging transaction errors.
 func failMsgsDB(qlog mlog.Log, msgs []*Msg, dialedIPs map[string][]net.IP, backoff time.Duration, remoteMTA dsn.NameIP, err error) {
+	var remove, dead []Msg
 	xerr := DB.Write(context.Background(), func(tx *bstore.Tx) error {
-		failMsgsTx(qlog, tx, msgs, dialedIPs, backoff, remoteMTA, err)
+		remove, dead = failMsgsTx(qlog, tx, msgs, dialedIPs, backoff, remoteMTA, err)
 		return nil
 	})
-	if xerr != nil {
+	if xerr == nil {
+		failMsgsFS(qlog, remove, dead)
+	} else {
 		for _, m := range msgs {
 			qlog.Errorx("error marking delivery as failed", xerr,
 				slog.String("delivererr", err.Error()),
@@ -56,13 +63,26 @@ func failMsgsDB(qlog mlog.Log, msgs []*Msg, dialedIPs map[string][]net.IP, backo
 	kick()
 }
 
+// failMsgsFS removes message files of permanently failed messages, and moves
+// message files of messages kept in the dead-letter queue, as returned by
+// failMsgsTx, after committing its transaction.
+func failMsgsFS(qlog mlog.Log, remove, dead []Msg) {
+	if err := removeMsgsFS(qlog, remove...); err != nil {
+		qlog.Errorx("remove queue messages from file system after permanent failure", err)
+	}
+	deadLetterMsgsFS(qlog, dead...)
+}
+
 // todo: perhaps put some of the params in a delivery struct so we don't pass all the params all the time?
 
 // failMsgsTx processes a failure to deliver msgs. If the error is permanent, a DSN
 // is delivered to the sender account.
 // Caller must call kick() after commiting the transaction for any (re)scheduling
-// of messages and webhooks.
-func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string][]net.IP, backoff time.Duration, remoteMTA dsn.NameIP, err error) {
+// of messages and webhooks. For permanent failures, the messages are removed from
+// the queue, and the caller must call failMsgsFS with the returned messages after
+// committing the transaction, to remove the message files or move them to the
+// dead-letter queue.
+func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string][]net.IP, backoff time.Duration, remoteMTA dsn.NameIP, err error) (remove, dead []Msg) {
 	// todo future: when we implement relaying, we should be able to send DSNs to non-local users. and possibly specify a null mailfrom. ../rfc/5321:1503
 	// todo future: when we implement relaying, and a dsn cannot be delivered, and requiretls was active, we cannot drop the message. instead deliver to local postmaster? though ../rfc/8689:383 may intend to say the dsn should be delivered without requiretls?
 	// todo future: when we implement smtp dsn extension, parameter RET=FULL must be disregarded for messages with REQUIRETLS. ../rfc/8689:379
@@ -77,9 +97,7 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 	var secodeOpt string
 	var event webhook.OutgoingEvent
 	if errors.As(err, &cerr) {
-		if cerr.Line != "" {
-			smtpLines = append([]string{cerr.Line}, cerr.MoreLines...)
-		}
+		smtpLines = responseLines(cerr.Line, cerr.MoreLines)
 		permanent = cerr.Permanent
 		code = cerr.Code
 		secodeOpt = cerr.Secode
@@ -95,7 +113,8 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 		ids[i] = m.ID
 	}
 
-	if permanent || m0.MaxAttempts == 0 && m0.Attempts >= 8 || m0.MaxAttempts > 0 && m0.Attempts >= m0.MaxAttempts {
+	rs := findRetrySchedule(*m0)
+	if permanent || retryExhausted(rs, *m0, time.Now()) {
 		event = webhook.EventFailed
 		if errors.Is(err, errSuppressed) {
 			event = webhook.EventSuppressed
@@ -106,7 +125,7 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 		for i, m := range msgs {
 			rm := *m
 			rm.DialedIPs = dialedIPs
-			rm.markResult(code, secodeOpt, errmsg, false)
+			rm.markResult(code, secodeOpt, smtpLines, errmsg, false)
 
 			qmlog := qlog.With(slog.Int64("msgid", rm.ID), slog.Any("recipient", m.Recipient()))
 			qmlog.Errorx("permanent failure delivering from queue", err)
@@ -119,12 +138,14 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 			if code == 0 {
 				continue
 			}
+			outgoingFailed(qmlog, rm, secodeOpt)
 			sc := suppressionCheck{
 				MsgID:     rm.ID,
 				Account:   rm.SenderAccount,
 				Recipient: rm.Recipient(),
 				Code:      code,
 				Secode:    secodeOpt,
+				Lines:     smtpLines,
 				Source:    "queue",
 			}
 			scl = append(scl, sc)
@@ -141,22 +162,30 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 		err := retireMsgs(qlog, tx, event, code, secodeOpt, suppressedMsgIDs, rmsgs...)
 		if err != nil {
 			qlog.Errorx("deleting queue messages from database after permanent failure", err)
-		} else if err := removeMsgsFS(qlog, rmsgs...); err != nil {
-			qlog.Errorx("remove queue messages from file system after permanent failure", err)
+			return
+		}
+		remove = rmsgs
+		if event == webhook.EventFailed {
+			dead, remove, err = deadLetterMsgs(tx, rmsgs)
+			if err != nil {
+				// Error adding to the dead-letter queue, we cannot undo changes in tx, so
+				// continue without keeping the messages.
+				qlog.Errorx("adding messages to dead-letter queue after permanent failure", err)
+				dead, remove = nil, rmsgs
+			}
 		}
-
 		return
 	}
 
-	if m0.Attempts == 5 {
-		// We've attempted deliveries at these intervals: 0, 7.5m, 15m, 30m, 1h, 2u.
-		// Let sender know delivery is delayed.
+	if m0.Attempts == delayedDSNAttempt {
+		// With the default schedule, we've attempted deliveries at these intervals: 0,
+		// 7.5m, 15m, 30m, 1h. Let sender know delivery is delayed.
 
-		retryUntil := m0.LastAttempt.Add((4 + 8 + 16) * time.Hour)
+		until := retryUntil(rs, *m0, *m0.LastAttempt)
 		for _, m := range msgs {
 			qmlog := qlog.With(slog.Int64("msgid", m.ID), slog.Any("recipient", m.Recipient()))
 			qmlog.Errorx("temporary failure delivering from queue, sending delayed dsn", err, slog.Duration("backoff", backoff))
-			deliverDSNDelay(qmlog, *m, remoteMTA, secodeOpt, errmsg, smtpLines, retryUntil)
+			deliverDSNDelay(qmlog, *m, remoteMTA, secodeOpt, errmsg, smtpLines, until)
 		}
 	} else {
 		for _, m := range msgs {
@@ -179,7 +208,14 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 		for _, um := range umsgs {
 			// All messages should have the same DialedIPs.
 			um.DialedIPs = dialedIPs
-			um.markResult(code, secodeOpt, errmsg, false)
+			// Session details are only in our in-memory copy.
+			for _, m := range msgs {
+				if m.ID == um.ID {
+					um.markSession(m.LastResult())
+					break
+				}
+			}
+			um.markResult(code, secodeOpt, smtpLines, errmsg, false)
 			if err := tx.Update(&um); err != nil {
 				return fmt.Errorf("updating message after temporary failure to deliver: %v", err)
 			}
@@ -194,7 +230,7 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 		hooks := make([]Hook, len(msgs))
 		for i, m := range msgs {
 			var err error
-			hooks[i], err = hookCompose(*m, accConf.OutgoingWebhook.URL, accConf.OutgoingWebhook.Authorization, webhook.EventDelayed, false, code, secodeOpt)
+			hooks[i], err = hookCompose(*m, *accConf.OutgoingWebhook, webhook.EventDelayed, false, code, secodeOpt)
 			if err != nil {
 				return fmt.Errorf("composing webhook for failed delivery attempt for msg id %d: %v", m.ID, err)
 			}
@@ -211,9 +247,35 @@ func failMsgsTx(qlog mlog.Log, tx *bstore.Tx, msgs []*Msg, dialedIPs map[string]
 	if err := process(); err != nil {
 		qlog.Errorx("processing temporary delivery error", err, slog.String("deliveryerror", errmsg))
 	}
+	return nil, nil
+}
+
+// outgoingFailed registers the failed recipient of a message rejected by the
+// remote server with the sender account, for detecting anomalies in outgoing
+// messages. Reports sent by us are not from users, they are skipped.
+func outgoingFailed(log mlog.Log, m Msg, secode string) {
+	if m.IsDMARCReport || m.IsTLSReport || m.SenderAccount == "" {
+		return
+	}
+	acc, err := store.OpenAccount(log, m.SenderAccount, false)
+	if err != nil {
+		log.Errorx("open account for registering outgoing failure", err, slog.String("account", m.SenderAccount))
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account after registering outgoing failure")
+	}()
+	err = acc.OutgoingFailureAdd(mox.Shutdown, m.Recipient().XString(true), secode)
+	log.Check(err, "registering outgoing failure")
 }
 
 func deliverDSNFailure(log mlog.Log, m Msg, remoteMTA dsn.NameIP, secodeOpt, errmsg string, smtpLines []string) {
+	if m.IsListMessage {
+		ListBounce(log, m.Sender(), m.Recipient())
+		return
+	}
+
 	const subject = "mail delivery failed"
 	message := fmt.Sprintf(`
 Delivery has failed permanently for your email to:
@@ -236,7 +298,8 @@ Error during the last delivery attempt:
 func deliverDSNDelay(log mlog.Log, m Msg, remoteMTA dsn.NameIP, secodeOpt, errmsg string, smtpLines []string, retryUntil time.Time) {
 	// Should not happen, but doesn't hurt to prevent sending delayed delivery
 	// notifications for DMARC reports. We don't want to waste postmaster attention.
-	if m.IsDMARCReport {
+	// Mailing lists only care about permanent failures.
+	if m.IsDMARCReport || m.IsListMessage {
 		return
 	}
 
@@ -2,6 +2,9 @@ package queue
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -20,6 +23,7 @@ import (
 
 	"github.com/mjl-/bstore"
 
+	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/dsn"
 	"github.com/mjl-/mox/message"
@@ -576,13 +580,10 @@ func HookCancel(ctx context.Context, log mlog.Log, filter HookFilter) (affected
 	return affected, nil
 }
 
-func hookCompose(m Msg, url, authz string, event webhook.OutgoingEvent, suppressing bool, code int, secodeOpt string) (Hook, error) {
+func hookCompose(m Msg, hook config.OutgoingWebhook, event webhook.OutgoingEvent, suppressing bool, code int, secodeOpt string) (Hook, error) {
 	now := time.Now()
 
-	var lastError string
-	if len(m.Results) > 0 {
-		lastError = m.Results[len(m.Results)-1].Error
-	}
+	lr := m.LastResult()
 	var ecode string
 	if secodeOpt != "" {
 		ecode = fmt.Sprintf("%d.%s", code/100, secodeOpt)
@@ -595,14 +596,36 @@ func hookCompose(m Msg, url, authz string, event webhook.OutgoingEvent, suppress
 		MessageID:        m.MessageID,
 		Subject:          m.Subject,
 		WebhookQueued:    now,
-		Error:            lastError,
+		Error:            lr.Error,
 		SMTPCode:         code,
 		SMTPEnhancedCode: ecode,
 		Extra:            m.Extra,
+		Recipient:        m.Recipient().XString(true),
+		Attempts:         m.Attempts,
+		RemoteMTA:        lr.RemoteMTA,
+		SMTPResponse:     lr.Response,
+		TLS:              lr.TLS,
+		MTASTS:           lr.MTASTS,
+		DANE:             lr.DANE,
+	}
+	if event == webhook.EventDelayed {
+		rs := findRetrySchedule(m)
+		data.MaxAttempts = retryMaxAttempts(rs, m)
+		next := m.NextAttempt
+		data.NextAttempt = &next
+	}
+	if event == webhook.EventFailed || event == webhook.EventDelayed {
+		data.BounceClass = bounceClassify(code, secodeOpt, lr.Response)
+	}
+	if hook.Transcript {
+		data.Transcript = lr.Transcript
 	}
 	if data.Extra == nil {
 		data.Extra = map[string]string{}
 	}
+	if data.SMTPResponse == nil {
+		data.SMTPResponse = []string{}
+	}
 	payload, err := json.Marshal(data)
 	if err != nil {
 		return Hook{}, fmt.Errorf("marshal webhook payload: %v", err)
@@ -615,8 +638,8 @@ func hookCompose(m Msg, url, authz string, event webhook.OutgoingEvent, suppress
 		Subject:       m.Subject,
 		Extra:         m.Extra,
 		Account:       m.SenderAccount,
-		URL:           url,
-		Authorization: authz,
+		URL:           hook.URL,
+		Authorization: hook.Authorization,
 		IsIncoming:    false,
 		OutgoingEvent: string(event),
 		Payload:       string(payload),
@@ -639,7 +662,6 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 		slog.String("mailbox", mailboxName),
 	)
 
-	// todo future: if there is no fromid in our rcpt address, but this is a 3-part dsn with headers that includes message-id, try matching based on that.
 	// todo future: once we implement the SMTP DSN extension, use ENVID when sending (if destination implements it), and start looking for Original-Envelope-ID in the DSN.
 
 	// If this is a DSN for a message we sent, don't deliver a hook for incoming
@@ -660,14 +682,22 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 	var outgoingEvent webhook.OutgoingEvent
 	var queueMsgID int64
 	var subject string
-	if fromID != "" {
+	if fromID != "" || part.IsDSN() {
 		err := DB.Write(ctx, func(tx *bstore.Tx) (rerr error) {
-			mr, err := bstore.QueryTx[MsgRetired](tx).FilterNonzero(MsgRetired{FromID: fromID}).Get()
+			var mr MsgRetired
+			var err error
+			if fromID != "" {
+				mr, err = bstore.QueryTx[MsgRetired](tx).FilterNonzero(MsgRetired{FromID: fromID}).Get()
+			} else {
+				// Without fromid, we try to match the DSN on the message-id of the original
+				// message in the DSN, and the recipient.
+				mr, err = dsnRetiredMsg(log, tx, acc.Name, part)
+			}
 			if err == bstore.ErrAbsent {
-				log.Debug("no original message found for fromid", slog.String("fromid", fromID))
+				log.Debug("no original message found for incoming message", slog.String("fromid", fromID))
 				return nil
 			} else if err != nil {
-				return fmt.Errorf("looking up original message for fromid: %v", err)
+				return fmt.Errorf("looking up original message: %v", err)
 			}
 
 			queueMsgID = mr.ID
@@ -686,6 +716,7 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 			var isDSN bool
 			var code int
 			var secode string
+			var bounceClass webhook.BounceClass
 			defer func() {
 				if rerr == nil {
 					var ecode string
@@ -703,7 +734,11 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 						WebhookQueued:    now,
 						SMTPCode:         code,
 						SMTPEnhancedCode: ecode,
+						BounceClass:      bounceClass,
 						Extra:            mr.Extra,
+						Recipient:        mr.Recipient().XString(true),
+						Attempts:         mr.Attempts,
+						SMTPResponse:     []string{},
 					}
 
 					if err := tx.Update(&mr); err != nil {
@@ -749,9 +784,15 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 			result.Secode = secode
 			log.Debug("incoming dsn message", slog.String("action", string(dsnrcpt.Action)), slog.Int("dsncode", code), slog.String("dsnsecode", secode))
 
+			var diagLines []string
+			if dsnrcpt.DiagnosticCodeSMTP != "" {
+				diagLines = []string{dsnrcpt.DiagnosticCodeSMTP}
+			}
+
 			switch s := dsnrcpt.Action; s {
 			case dsn.Failed:
 				outgoingEvent = webhook.EventFailed
+				bounceClass = bounceClassify(code, secode, diagLines)
 
 				if code != 0 {
 					sc := suppressionCheck{
@@ -760,6 +801,7 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 						Recipient: mr.Recipient(),
 						Code:      code,
 						Secode:    secode,
+						Lines:     diagLines,
 						Source:    "DSN",
 					}
 					suppressedMsgIDs, err = suppressionProcess(log, tx, sc)
@@ -773,6 +815,9 @@ func Incoming(ctx context.Context, log mlog.Log, acc *store.Account, messageID s
 			case dsn.Delayed, dsn.Delivered, dsn.Relayed, dsn.Expanded:
 				outgoingEvent = webhook.OutgoingEvent(string(s))
 				result.Success = s != dsn.Delayed
+				if s == dsn.Delayed {
+					bounceClass = bounceClassify(code, secode, diagLines)
+				}
 
 			default:
 				log.Info("unrecognized dsn action", slog.String("action", string(dsnrcpt.Action)))
@@ -1174,8 +1219,16 @@ func hookDeliver(log mlog.Log, h Hook) {
 
 	hctx, cancel := context.WithTimeout(ctx, 60*time.Second)
 	defer cancel()
+	// The signing key is not stored with the hook, we use the current configuration.
+	var signingKey string
+	accConf, _ := mox.Conf.Account(h.Account)
+	if h.IsIncoming && accConf.IncomingWebhook != nil {
+		signingKey = accConf.IncomingWebhook.SigningKey
+	} else if !h.IsIncoming && accConf.OutgoingWebhook != nil {
+		signingKey = accConf.OutgoingWebhook.SigningKey
+	}
 	t0 := time.Now()
-	code, response, err := HookPost(hctx, qlog, h.ID, h.Attempts, h.URL, h.Authorization, h.Payload)
+	code, response, err := HookPost(hctx, qlog, h.ID, h.Attempts, h.URL, h.Authorization, signingKey, h.Payload)
 	result.Duration = time.Since(t0)
 	result.Success = err == nil
 	result.Code = code
@@ -1246,7 +1299,10 @@ func hookTransport() *http.Transport {
 	return t
 }
 
-func HookPost(ctx context.Context, log mlog.Log, hookID int64, attempt int, url, authz string, payload string) (code int, response string, err error) {
+// HookPost makes an HTTP POST request with the webhook payload to url. If
+// signingKey is not empty, an HMAC-SHA256 signature of the payload is added in
+// header X-Mox-Webhook-Signature.
+func HookPost(ctx context.Context, log mlog.Log, hookID int64, attempt int, url, authz, signingKey string, payload string) (code int, response string, err error) {
 	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(payload))
 	if err != nil {
 		return 0, "", fmt.Errorf("new request: %v", err)
@@ -1258,6 +1314,9 @@ func HookPost(ctx context.Context, log mlog.Log, hookID int64, attempt int, url,
 	if authz != "" {
 		req.Header.Set("Authorization", authz)
 	}
+	if signingKey != "" {
+		req.Header.Set("X-Mox-Webhook-Signature", HookSignature(signingKey, payload))
+	}
 	t0 := time.Now()
 	resp, err := hookClient.Do(req)
 	metricHookRequest.Observe(float64(time.Since(t0)) / float64(time.Second))
@@ -1285,3 +1344,11 @@ func HookPost(ctx context.Context, log mlog.Log, hookID int64, attempt int, url,
 	}
 	return resp.StatusCode, string(respbuf), err
 }
+
+// HookSignature returns the value for the X-Mox-Webhook-Signature header: the
+// hex-encoded HMAC-SHA256 of the payload, prefixed with "sha256=".
+func HookSignature(key, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
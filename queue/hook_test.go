@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"slices"
@@ -192,6 +193,8 @@ func TestFromIDIncomingDelivery(t *testing.T) {
 
 		if expOut != nil {
 			expOut.QueueMsgID = qmr.ID
+			expOut.Recipient = qmr.Recipient().XString(true)
+			expOut.SMTPResponse = []string{}
 		}
 
 		mr := bytes.NewReader(rawmsg)
@@ -270,6 +273,7 @@ func TestFromIDIncomingDelivery(t *testing.T) {
 		FromID:           "unique",
 		SMTPCode:         554,
 		SMTPEnhancedCode: "5.0.0",
+		BounceClass:      webhook.BouncePolicy,
 	})
 
 	msgrelayed := dsncompose(makedsn(dsn.Relayed))
@@ -317,6 +321,7 @@ func TestFromIDIncomingDelivery(t *testing.T) {
 		FromID:           "unique",
 		SMTPCode:         554,
 		SMTPEnhancedCode: "5.0.0",
+		BounceClass:      webhook.BouncePolicy,
 	})
 
 	// We still have a webhook in the queue from the test above.
@@ -344,6 +349,11 @@ func TestFromIDIncomingDelivery(t *testing.T) {
 			http.Error(w, "missing header x-mox-webhook-attempt", http.StatusBadRequest)
 			return
 		}
+		buf, err := io.ReadAll(r.Body)
+		if err != nil || r.Header.Get("X-Mox-Webhook-Signature") != HookSignature("testkey", string(buf)) {
+			http.Error(w, "bad signature", http.StatusBadRequest)
+			return
+		}
 		fmt.Fprintln(w, "ok")
 	})
 	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -420,6 +430,7 @@ func TestFromIDIncomingDelivery(t *testing.T) {
 			FromID:           "unique",
 			SMTPCode:         554,
 			SMTPEnhancedCode: "5.0.0",
+			BounceClass:      webhook.BouncePolicy,
 		})
 	}
 
@@ -521,6 +532,108 @@ func TestFromIDIncomingDelivery(t *testing.T) {
 	tcompare(t, h2.ID > h.ID, true)
 }
 
+// Test matching an incoming DSN without fromid to a retired message, based on the
+// message-id of the original message included in the DSN.
+func TestDSNMessageIDIncoming(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	accret, err := store.OpenAccount(pkglog, "retired", false)
+	tcheck(t, err, "open account for retired")
+	defer func() {
+		accret.Close()
+		accret.WaitClosed()
+	}()
+
+	addr, err := smtp.ParseAddress("mjl@mox.example")
+	tcheck(t, err, "parse address")
+	path := addr.Path()
+
+	now := time.Now().Round(0)
+	qmr := MsgRetired{
+		SenderAccount:      accret.Name,
+		SenderLocalpart:    "sender",
+		SenderDomainStr:    "remote.example",
+		RecipientLocalpart: "mjl",
+		RecipientDomain:    path.IPDomain,
+		RecipientDomainStr: "mox.example",
+		RecipientAddress:   "mjl@mox.example",
+		MessageID:          "<original@mox.example>",
+		KeepUntil:          now.Add(time.Minute),
+	}
+	err = DB.Insert(ctxbg, &qmr)
+	tcheck(t, err, "insert retired message to match")
+
+	testDSN := func(messageID string, expOut *webhook.Outgoing) {
+		t.Helper()
+
+		_, err := bstore.QueryDB[Hook](ctxbg, DB).Delete()
+		tcheck(t, err, "clean up hooks")
+
+		dsnmsg := &dsn.Message{
+			From:         path,
+			To:           path,
+			TextBody:     "explanation",
+			MessageID:    "<dsnmsgid@localhost>",
+			ReportingMTA: "localhost",
+			Recipients: []dsn.Recipient{
+				{
+					FinalRecipient:     path,
+					Action:             dsn.Failed,
+					Status:             "5.1.1",
+					DiagnosticCodeSMTP: "550 5.1.1 user unknown",
+				},
+			},
+			Original: []byte("Message-Id: " + messageID + "\r\nSubject: test\r\n\r\n"),
+		}
+		rawmsg, err := dsnmsg.Compose(pkglog, false)
+		tcheck(t, err, "compose dsn")
+		part, err := message.EnsurePart(pkglog.Logger, true, bytes.NewReader(rawmsg), int64(len(rawmsg)))
+		tcheck(t, err, "parsing message")
+
+		// Without fromid in the recipient address.
+		m := store.Message{ID: 123, RcptToLocalpart: "mjl", RcptToDomain: "mox.example", Size: int64(len(rawmsg)), Received: now, DSN: true}
+		err = Incoming(ctxbg, pkglog, accret, "<random@localhost>", m, part, "Inbox")
+		tcheck(t, err, "pass incoming message")
+
+		hl, err := bstore.QueryDB[Hook](ctxbg, DB).List()
+		tcheck(t, err, "list hooks")
+		tcompare(t, len(hl), 1)
+		if expOut == nil {
+			tcompare(t, hl[0].IsIncoming, true)
+			return
+		}
+		tcompare(t, hl[0].IsIncoming, false)
+		var out webhook.Outgoing
+		err = json.NewDecoder(strings.NewReader(hl[0].Payload)).Decode(&out)
+		tcheck(t, err, "decode outgoing webhook")
+		out.WebhookQueued = time.Time{}
+		tcompare(t, &out, expOut)
+	}
+
+	// Unknown message-id, treated as regular incoming message.
+	testDSN("<other@mox.example>", nil)
+
+	testDSN("<original@mox.example>", &webhook.Outgoing{
+		Event:            webhook.EventFailed,
+		DSN:              true,
+		Suppressing:      true,
+		QueueMsgID:       qmr.ID,
+		MessageID:        "<original@mox.example>",
+		SMTPCode:         550,
+		SMTPEnhancedCode: "5.1.1",
+		BounceClass:      webhook.BounceHard,
+		Recipient:        "mjl@mox.example",
+		SMTPResponse:     []string{},
+	})
+
+	// The hard bounce added the recipient to the suppression list.
+	sup, err := SuppressionLookup(ctxbg, accret.Name, path)
+	tcheck(t, err, "lookup suppression")
+	tcompare(t, sup != nil, true)
+	tcompare(t, sup.BounceClass, webhook.BounceHard)
+}
+
 func TestHookListFilterSort(t *testing.T) {
 	_, cleanup := setup(t)
 	defer cleanup()
@@ -0,0 +1,174 @@
+package queue
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+// HostHealth tracks the outcome of delivery attempts to a remote host, typically
+// an MX host. Hosts with recent failures are put in a cooldown period, during
+// which deliveries are first attempted to other hosts with the same MX preference.
+type HostHealth struct {
+	Host string // ASCII domain name or IP address. Primary key.
+
+	LastAttempt time.Time
+	LastSuccess time.Time
+	LastFailure time.Time
+	LastError   string
+
+	Attempts          int64
+	Successes         int64 // Connections with an SMTP transaction, also when the remote server refused delivery permanently.
+	ConnectFailures   int64 // Connection could not be made, including failures to look up IPs.
+	TLSFailures       int64 // STARTTLS or TLS verification failed.
+	TemporaryFailures int64 // 4xx responses, or connections that failed during the SMTP transaction.
+
+	ConsecutiveFailures int
+	CooldownUntil       time.Time // If in the future, other hosts with same MX preference are tried first.
+}
+
+// hostHealthCooldown returns the cooldown period after consecutive failures. It
+// starts at one minute and doubles with each failure, up to an hour.
+func hostHealthCooldown(consecutive int) time.Duration {
+	return min(time.Minute<<min(max(consecutive-1, 0), 6), time.Hour)
+}
+
+func hostHealthKey(h dns.IPDomain) string {
+	if len(h.IP) > 0 {
+		return h.IP.String()
+	}
+	return h.Domain.ASCII
+}
+
+// hostHealthOrder returns hosts ordered for delivery attempts. Hosts are kept in
+// order of MX preference, but within the same preference, hosts in cooldown are
+// moved after healthy hosts, ordered by end of cooldown. If prefs is nil (no MX
+// records), hosts are returned as is.
+func hostHealthOrder(ctx context.Context, log mlog.Log, hosts []dns.IPDomain, prefs []uint16) []dns.IPDomain {
+	if len(hosts) < 2 || len(prefs) != len(hosts) {
+		return hosts
+	}
+
+	cooldown := map[string]time.Time{}
+	now := time.Now()
+	err := DB.Read(ctx, func(tx *bstore.Tx) error {
+		for _, h := range hosts {
+			hh := HostHealth{Host: hostHealthKey(h)}
+			if err := tx.Get(&hh); err == bstore.ErrAbsent {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if hh.CooldownUntil.After(now) {
+				cooldown[hh.Host] = hh.CooldownUntil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Errorx("reading host health for ordering hosts, keeping order", err)
+		return hosts
+	}
+	if len(cooldown) == 0 {
+		return hosts
+	}
+
+	type prefHost struct {
+		host dns.IPDomain
+		pref uint16
+	}
+	l := make([]prefHost, len(hosts))
+	for i, h := range hosts {
+		l[i] = prefHost{h, prefs[i]}
+	}
+	// Stable, so the randomized order within a preference from DNS is kept.
+	slices.SortStableFunc(l, func(a, b prefHost) int {
+		if a.pref != b.pref {
+			return int(a.pref) - int(b.pref)
+		}
+		ac, bc := cooldown[hostHealthKey(a.host)], cooldown[hostHealthKey(b.host)]
+		return ac.Compare(bc)
+	})
+	r := make([]dns.IPDomain, len(l))
+	for i, ph := range l {
+		r[i] = ph.host
+	}
+	if !slices.EqualFunc(r, hosts, func(a, b dns.IPDomain) bool { return hostHealthKey(a) == hostHealthKey(b) }) {
+		log.Info("ordered hosts by health, trying hosts in cooldown last", slog.Any("hosts", r), slog.Any("cooldown", cooldown))
+	}
+	return r
+}
+
+// hostHealthRecord updates the health of host with the result of a delivery
+// attempt.
+func hostHealthRecord(log mlog.Log, host dns.IPDomain, result deliverResult) {
+	var connectFailure, tlsFailure, temporaryFailure bool
+	var cerr smtpclient.Error
+	switch {
+	case result.err == nil:
+	case result.connectFailed:
+		connectFailure = true
+	case errors.Is(result.err, smtpclient.ErrTLS):
+		tlsFailure = true
+	case errors.As(result.err, &cerr) && cerr.Permanent:
+		// Remote responded, the host is working.
+	default:
+		temporaryFailure = true
+	}
+
+	now := time.Now()
+	err := DB.Write(context.Background(), func(tx *bstore.Tx) error {
+		hh := HostHealth{Host: hostHealthKey(host)}
+		err := tx.Get(&hh)
+		if err != nil && err != bstore.ErrAbsent {
+			return err
+		}
+		exists := err == nil
+
+		hh.LastAttempt = now
+		hh.Attempts++
+		if connectFailure || tlsFailure || temporaryFailure {
+			hh.LastFailure = now
+			hh.LastError = result.err.Error()
+			if connectFailure {
+				hh.ConnectFailures++
+			} else if tlsFailure {
+				hh.TLSFailures++
+			} else {
+				hh.TemporaryFailures++
+			}
+			hh.ConsecutiveFailures++
+			hh.CooldownUntil = now.Add(hostHealthCooldown(hh.ConsecutiveFailures))
+		} else {
+			hh.LastSuccess = now
+			hh.Successes++
+			hh.ConsecutiveFailures = 0
+			hh.CooldownUntil = time.Time{}
+		}
+
+		if exists {
+			return tx.Update(&hh)
+		}
+		return tx.Insert(&hh)
+	})
+	log.Check(err, "updating host health", slog.Any("host", host))
+}
+
+// HostHealthList returns the health of remote hosts, most recently attempted
+// first.
+func HostHealthList(ctx context.Context) ([]HostHealth, error) {
+	return bstore.QueryDB[HostHealth](ctx, DB).SortDesc("LastAttempt").List()
+}
+
+// HostHealthReset removes the health record for host, ending a cooldown period.
+func HostHealthReset(ctx context.Context, host string) error {
+	return DB.Delete(ctx, &HostHealth{Host: host})
+}
@@ -0,0 +1,96 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+func TestHostHealth(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	log := mlog.New("queue", nil)
+
+	tcompare(t, hostHealthCooldown(1), time.Minute)
+	tcompare(t, hostHealthCooldown(2), 2*time.Minute)
+	tcompare(t, hostHealthCooldown(7), time.Hour)
+	tcompare(t, hostHealthCooldown(100), time.Hour)
+
+	host := func(s string) dns.IPDomain {
+		return dns.IPDomain{Domain: dns.Domain{ASCII: s}}
+	}
+	names := func(l []dns.IPDomain) (r []string) {
+		for _, h := range l {
+			r = append(r, h.Domain.ASCII)
+		}
+		return r
+	}
+	get := func(name string) HostHealth {
+		t.Helper()
+		hh := HostHealth{Host: name}
+		err := DB.Get(ctxbg, &hh)
+		tcheck(t, err, "get host health")
+		return hh
+	}
+
+	hosts := []dns.IPDomain{host("mx1.example"), host("mx2.example"), host("mx3.example"), host("mx4.example")}
+	prefs := []uint16{10, 10, 20, 20}
+
+	// No health records, order is kept.
+	tcompare(t, names(hostHealthOrder(ctxbg, log, hosts, prefs)), names(hosts))
+
+	// Connection failure puts mx1 in cooldown, it moves after mx2 but not after mx3
+	// with a higher preference.
+	hostHealthRecord(log, hosts[0], deliverResult{err: errors.New("dial"), connectFailed: true})
+	hh := get("mx1.example")
+	tcompare(t, hh.ConnectFailures, int64(1))
+	tcompare(t, hh.ConsecutiveFailures, 1)
+	tcompare(t, hh.CooldownUntil.After(time.Now()), true)
+	tcompare(t, names(hostHealthOrder(ctxbg, log, hosts, prefs)), []string{"mx2.example", "mx1.example", "mx3.example", "mx4.example"})
+
+	// Without MX preferences, e.g. delivery to a host without MX records, order is kept.
+	tcompare(t, names(hostHealthOrder(ctxbg, log, hosts, nil)), names(hosts))
+
+	// TLS failure and temporary failures are counted, and extend cooldown.
+	hostHealthRecord(log, hosts[0], deliverResult{err: fmt.Errorf("%w: handshake", smtpclient.ErrTLS)})
+	hostHealthRecord(log, hosts[0], deliverResult{err: smtpclient.Error{Code: 451, Err: errors.New("try again")}})
+	hh2 := get("mx1.example")
+	tcompare(t, hh2.TLSFailures, int64(1))
+	tcompare(t, hh2.TemporaryFailures, int64(1))
+	tcompare(t, hh2.ConsecutiveFailures, 3)
+	tcompare(t, hh2.CooldownUntil.After(hh.CooldownUntil), true)
+
+	// Both hosts of a preference in cooldown, the one ending earliest goes first.
+	hostHealthRecord(log, hosts[1], deliverResult{err: errors.New("dial"), connectFailed: true})
+	tcompare(t, names(hostHealthOrder(ctxbg, log, hosts, prefs)), []string{"mx2.example", "mx1.example", "mx3.example", "mx4.example"})
+
+	// Permanent error means the host is working, it ends the cooldown.
+	hostHealthRecord(log, hosts[0], deliverResult{err: smtpclient.Error{Permanent: true, Code: 550, Err: errors.New("no such user")}})
+	hh = get("mx1.example")
+	tcompare(t, hh.Successes, int64(1))
+	tcompare(t, hh.ConsecutiveFailures, 0)
+	tcompare(t, hh.CooldownUntil.IsZero(), true)
+	tcompare(t, names(hostHealthOrder(ctxbg, log, hosts, prefs)), names(hosts))
+
+	hostHealthRecord(log, hosts[2], deliverResult{})
+
+	l, err := HostHealthList(ctxbg)
+	tcheck(t, err, "list host health")
+	tcompare(t, len(l), 3)
+	tcompare(t, l[0].Host, "mx3.example")
+
+	err = HostHealthReset(ctxbg, "mx2.example")
+	tcheck(t, err, "reset host health")
+	l, err = HostHealthList(ctxbg)
+	tcheck(t, err, "list host health")
+	tcompare(t, len(l), 2)
+
+	err = HostHealthReset(ctxbg, "mx2.example")
+	tcompare(t, err != nil, true)
+}
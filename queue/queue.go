@@ -79,8 +79,8 @@ var (
 
 var jitter = mox.NewPseudoRand()
 
-var DBTypes = []any{Msg{}, HoldRule{}, MsgRetired{}, webapi.Suppression{}, Hook{}, HookRetired{}} // Types stored in DB.
-var DB *bstore.DB                                                                                 // Exported for making backups.
+var DBTypes = []any{Msg{}, HoldRule{}, MsgRetired{}, webapi.Suppression{}, Hook{}, HookRetired{}, MsgDead{}, HostHealth{}} // Types stored in DB.
+var DB *bstore.DB                                                                                                          // Exported for making backups.
 
 // Allow requesting delivery starting from up to this interval from time of submission.
 const FutureReleaseIntervalMax = 60 * 24 * time.Hour
@@ -105,8 +105,12 @@ func (pr HoldRule) All() bool {
 	return pr == HoldRule{}
 }
 
+// matches returns whether all non-empty conditions of the hold rule match the
+// message, consistent with how HoldRuleAdd selects existing messages.
 func (pr HoldRule) matches(m Msg) bool {
-	return pr.All() || pr.Account == m.SenderAccount || pr.SenderDomainStr == m.SenderDomainStr || pr.RecipientDomainStr == m.RecipientDomainStr
+	return (pr.Account == "" || pr.Account == m.SenderAccount) &&
+		(pr.SenderDomainStr == "" || pr.SenderDomainStr == m.SenderDomainStr) &&
+		(pr.RecipientDomainStr == "" || pr.RecipientDomainStr == m.RecipientDomainStr)
 }
 
 // Msg is a message in the queue.
@@ -125,6 +129,7 @@ type Msg struct {
 
 	Queued             time.Time      `bstore:"default now"`
 	Hold               bool           // If set, delivery won't be attempted.
+	HoldManual         bool           // If set, Hold was set explicitly for the message, not by a hold rule. Resuming a hold rule doesn't take the message off hold.
 	SenderAccount      string         // Failures are delivered back to this local account. Also used for routing.
 	SenderLocalpart    smtp.Localpart // Should be a local user and domain.
 	SenderDomain       dns.IPDomain
@@ -144,6 +149,7 @@ type Msg struct {
 	SMTPUTF8      bool   // Whether message requires use of SMTPUTF8.
 	IsDMARCReport bool   // Delivery failures for DMARC reports are handled differently.
 	IsTLSReport   bool   // Delivery failures for TLS reports are handled differently.
+	IsListMessage bool   // Delivery failures for mailing list messages are handled by the list.
 	Size          int64  // Full size of message, combined MsgPrefix with contents of message file.
 	MessageID     string // Message-ID header, including <>. Used when composing a DSN, in its References header.
 	MsgPrefix     []byte // Data to send before the contents from the file, typically with headers like DKIM-Signature.
@@ -180,7 +186,17 @@ type Msg struct {
 	FutureReleaseRequest string
 	// ../rfc/4865:305
 
+	// Priority of the message, from -9 to 9, 0 being normal priority. Set through the
+	// SMTP MT-PRIORITY extension (RFC 6710). Messages with a higher priority are
+	// delivered first when multiple messages are due, messages with a priority above
+	// normal can use delivery slots reserved for them, retry schedules can match on
+	// priority, and the priority is passed on to remote servers that support
+	// MT-PRIORITY.
+	Priority int
+
 	Extra map[string]string // Extra information, for transactional email.
+
+	Changes []MsgChange // Changes made by admin to envelope or message, as audit trail.
 }
 
 // MsgResult is the result (or work in progress) of a delivery attempt.
@@ -191,25 +207,100 @@ type MsgResult struct {
 	Code     int
 	Secode   string
 	Error    string
-	// todo: store smtp trace for failed deliveries for debugging, perhaps also for successful deliveries.
+
+	// Details about the SMTP session, if a connection was made.
+
+	RemoteMTA  string   // Host name and IP address of remote server.
+	Response   []string // Full response from remote server, one or more lines.
+	TLS        string   // TLS version and cipher suite, empty if TLS was not used.
+	MTASTS     bool     // Whether TLS was verified with MTA-STS policy.
+	DANE       bool     // Whether TLS was verified with DANE.
+	Transcript string   // SMTP protocol transcript, without authentication and message data. Only kept for the most recent attempt.
+
+	// DNS lookups made for the delivery attempt, with whether they were
+	// DNSSEC-verified. Only kept for the most recent attempt.
+	DNSLookups []dns.Lookup
+
+	// Why DANE was or was not used to verify TLS: "insecure" (destination not
+	// DNSSEC-verified), "dnssec-error", "no-tlsa", "unusable" (only unusable TLSA
+	// records, STARTTLS required but not verified), "required", "tlsa-error",
+	// "ignored" (due to TLS-Required: No message header) or "skipped" (for plaintext
+	// fallback). Empty for deliveries through a transport other than direct.
+	DANEStatus string
 }
 
+// Transcripts of SMTP sessions are truncated to this size when stored with a
+// delivery result.
+const transcriptMax = 16 * 1024
+
 // Stored in MsgResult.Error while delivery is in progress. Replaced after success/error.
 const resultErrorDelivering = "delivering..."
 
-// markResult updates/adds a delivery result.
-func (m *Msg) markResult(code int, secode string, errmsg string, success bool) {
+// pendingResult returns the in-progress delivery result, adding one if needed.
+func (m *Msg) pendingResult() *MsgResult {
 	if len(m.Results) == 0 || m.Results[len(m.Results)-1].Error != resultErrorDelivering {
-		m.Results = append(m.Results, MsgResult{Start: time.Now()})
+		m.Results = append(m.Results, MsgResult{Start: time.Now(), Error: resultErrorDelivering})
 	}
-	result := &m.Results[len(m.Results)-1]
+	return &m.Results[len(m.Results)-1]
+}
+
+// markResult updates/adds a delivery result. Lines is the full SMTP response from
+// the remote server, if any.
+func (m *Msg) markResult(code int, secode string, lines []string, errmsg string, success bool) {
+	result := m.pendingResult()
 	result.Duration = time.Since(result.Start)
 	result.Code = code
 	result.Secode = secode
+	result.Response = lines
 	result.Error = errmsg
 	result.Success = success
 }
 
+// responseLines returns the lines of an SMTP response, or nil if there was no
+// response.
+func responseLines(line string, moreLines []string) []string {
+	if line == "" {
+		return nil
+	}
+	return append([]string{line}, moreLines...)
+}
+
+// sessionResult returns a result with details about an SMTP session, for use
+// with markSession. Client sc can be nil if no SMTP session was established.
+func sessionResult(sc *smtpclient.Client, remoteMTA, transcript string, mtasts, dane bool) MsgResult {
+	r := MsgResult{RemoteMTA: remoteMTA, Transcript: transcript}
+	if sc != nil {
+		if cs := sc.TLSConnectionState(); cs != nil {
+			version, ciphersuite := moxio.TLSInfo(*cs)
+			r.TLS = version + " " + ciphersuite
+			r.MTASTS = mtasts
+			r.DANE = dane
+		}
+	}
+	return r
+}
+
+// markSession stores details about the SMTP session of the in-progress delivery
+// attempt, as found in r. Transcripts and DNS lookups of earlier attempts are
+// cleared.
+func (m *Msg) markSession(r MsgResult) {
+	for i := range m.Results {
+		m.Results[i].Transcript = ""
+		m.Results[i].DNSLookups = nil
+	}
+	result := m.pendingResult()
+	result.RemoteMTA = r.RemoteMTA
+	result.TLS = r.TLS
+	result.MTASTS = r.MTASTS
+	result.DANE = r.DANE
+	result.DNSLookups = r.DNSLookups
+	result.DANEStatus = r.DANEStatus
+	result.Transcript = r.Transcript
+	if len(result.Transcript) > transcriptMax {
+		result.Transcript = result.Transcript[:transcriptMax] + "\n(truncated)\n"
+	}
+}
+
 // LastResult returns the last result entry, or an empty result.
 func (m *Msg) LastResult() MsgResult {
 	if len(m.Results) == 0 {
@@ -257,13 +348,16 @@ func (m Msg) Retired(success bool, t, keepUntil time.Time) MsgRetired {
 		SMTPUTF8:             m.SMTPUTF8,
 		IsDMARCReport:        m.IsDMARCReport,
 		IsTLSReport:          m.IsTLSReport,
+		IsListMessage:        m.IsListMessage,
 		Size:                 m.Size,
 		MessageID:            m.MessageID,
 		Subject:              m.Subject,
 		Transport:            m.Transport,
 		RequireTLS:           m.RequireTLS,
 		FutureReleaseRequest: m.FutureReleaseRequest,
+		Priority:             m.Priority,
 		Extra:                m.Extra,
+		Changes:              m.Changes,
 
 		RecipientAddress: smtp.Path{Localpart: m.RecipientLocalpart, IPDomain: m.RecipientDomain}.XString(true),
 		Success:          success,
@@ -297,6 +391,7 @@ type MsgRetired struct {
 	SMTPUTF8      bool   // Whether message requires use of SMTPUTF8.
 	IsDMARCReport bool   // Delivery failures for DMARC reports are handled differently.
 	IsTLSReport   bool   // Delivery failures for TLS reports are handled differently.
+	IsListMessage bool   // Delivery failures for mailing list messages are handled by the list.
 	Size          int64  // Full size of message, combined MsgPrefix with contents of message file.
 	MessageID     string // Used when composing a DSN, in its References header.
 	Subject       string // For context about delivery.
@@ -304,9 +399,12 @@ type MsgRetired struct {
 	Transport            string
 	RequireTLS           *bool
 	FutureReleaseRequest string
+	Priority             int
 
 	Extra map[string]string // Extra information, for transactional email.
 
+	Changes []MsgChange // Changes made by admin while in the queue.
+
 	LastActivity     time.Time `bstore:"index"`
 	RecipientAddress string    `bstore:"index RecipientAddress+LastActivity"`
 	Success          bool      // Whether delivery to next hop succeeded.
@@ -406,6 +504,8 @@ type Filter struct {
 	Submitted   string // Whether submitted before/after a time relative to now. ">$duration" or "<$duration", also with "now" for duration.
 	NextAttempt string // ">$duration" or "<$duration", also with "now" for duration.
 	Transport   *string
+	Domain      string // Recipient domain. If it starts with a dot, subdomains also match.
+	LastError   string // Substring of the error of the last delivery attempt, case-insensitive.
 }
 
 func (f Filter) apply(q *bstore.Query[Msg]) error {
@@ -460,6 +560,25 @@ func (f Filter) apply(q *bstore.Query[Msg]) error {
 			return f.From != "" && strings.Contains(m.Sender().XString(true), f.From) || f.To != "" && strings.Contains(m.Recipient().XString(true), f.To)
 		})
 	}
+	if f.Domain != "" {
+		d := strings.ToLower(f.Domain)
+		if strings.HasPrefix(d, ".") {
+			q.FilterFn(func(m Msg) bool {
+				rd := strings.ToLower(m.RecipientDomainStr)
+				return rd == d[1:] || strings.HasSuffix(rd, d)
+			})
+		} else {
+			q.FilterFn(func(m Msg) bool {
+				return strings.EqualFold(m.RecipientDomainStr, d)
+			})
+		}
+	}
+	if f.LastError != "" {
+		s := strings.ToLower(f.LastError)
+		q.FilterFn(func(m Msg) bool {
+			return strings.Contains(strings.ToLower(m.LastResult().Error), s)
+		})
+	}
 	if f.Max != 0 {
 		q.Limit(f.Max)
 	}
@@ -544,6 +663,33 @@ func Count(ctx context.Context) (int, error) {
 	return bstore.QueryDB[Msg](ctx, DB).Count()
 }
 
+// CountFilter returns the number of messages in the delivery queue matching
+// filter, ignoring its Max, e.g. for showing the total for paginated lists.
+func CountFilter(ctx context.Context, filter Filter) (int, error) {
+	filter.Max = 0
+	q := bstore.QueryDB[Msg](ctx, DB)
+	if err := filter.apply(q); err != nil {
+		return 0, err
+	}
+	return q.Count()
+}
+
+// Transcript returns the result of the most recent delivery attempt of a message
+// that has an SMTP transcript. Only the transcript of the most recent connection
+// is kept. A zero result is returned if there is no transcript.
+func Transcript(ctx context.Context, id int64) (MsgResult, error) {
+	m := Msg{ID: id}
+	if err := DB.Get(ctx, &m); err != nil {
+		return MsgResult{}, err
+	}
+	for i := len(m.Results) - 1; i >= 0; i-- {
+		if m.Results[i].Transcript != "" {
+			return m.Results[i], nil
+		}
+	}
+	return MsgResult{}, nil
+}
+
 // HoldRuleList returns all hold rules.
 func HoldRuleList(ctx context.Context) ([]HoldRule, error) {
 	return bstore.QueryDB[HoldRule](ctx, DB).List()
@@ -598,6 +744,56 @@ func HoldRuleRemove(ctx context.Context, log mlog.Log, holdRuleID int64) error {
 	})
 }
 
+// HoldRuleResume removes a hold rule and takes messages in the queue that match
+// the rule off hold, resuming their delivery. Messages that also match one of the
+// remaining hold rules stay on hold, as do messages put on hold explicitly with
+// HoldSet.
+func HoldRuleResume(ctx context.Context, log mlog.Log, holdRuleID int64) (affected int, err error) {
+	err = DB.Write(ctx, func(tx *bstore.Tx) error {
+		hr := HoldRule{ID: holdRuleID}
+		if err := tx.Get(&hr); err != nil {
+			return err
+		}
+		log.Info("resuming hold rule", slog.Any("holdrule", hr))
+		if err := tx.Delete(hr); err != nil {
+			return err
+		}
+
+		holdRules, err := bstore.QueryTx[HoldRule](tx).List()
+		if err != nil {
+			return fmt.Errorf("listing remaining hold rules: %v", err)
+		}
+
+		q := bstore.QueryTx[Msg](tx)
+		q.FilterNonzero(Msg{
+			Hold:               true,
+			SenderAccount:      hr.Account,
+			SenderDomainStr:    hr.SenderDomainStr,
+			RecipientDomainStr: hr.RecipientDomainStr,
+		})
+		q.FilterEqual("HoldManual", false)
+		q.FilterFn(func(m Msg) bool {
+			for _, ohr := range holdRules {
+				if ohr.matches(m) {
+					return false
+				}
+			}
+			return true
+		})
+		affected, err = q.UpdateFields(map[string]any{"Hold": false})
+		if err != nil {
+			return fmt.Errorf("taking matching messages in queue off hold: %v", err)
+		}
+		return metricHoldUpdate(tx)
+	})
+	if err != nil {
+		return 0, err
+	}
+	log.Info("took messages in queue off hold", slog.Int("messages", affected))
+	msgqueueKick()
+	return affected, nil
+}
+
 // MakeMsg is a convenience function that sets the commonly used fields for a Msg.
 // messageID should include <>.
 func MakeMsg(sender, recipient smtp.Path, has8bit, smtputf8 bool, size int64, messageID string, prefix []byte, requireTLS *bool, next time.Time, subject string) Msg {
@@ -629,15 +825,48 @@ func MakeMsg(sender, recipient smtp.Path, has8bit, smtputf8 bool, size int64, me
 // Add sets derived fields like SenderDomainStr and RecipientDomainStr, and fields
 // related to queueing, such as Queued, NextAttempt.
 func Add(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.File, qml ...Msg) error {
+	tx, err := DB.Begin(ctx, true)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() {
+		if tx != nil {
+			if err := tx.Rollback(); err != nil {
+				log.Errorx("rollback for queue", err)
+			}
+		}
+	}()
+
+	paths, err := addTx(log, tx, senderAccount, msgFile, qml...)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		removeAddedFiles(log, paths)
+		return fmt.Errorf("commit transaction: %s", err)
+	}
+	tx = nil
+
+	msgqueueKick()
+
+	return nil
+}
+
+// addTx inserts messages in the queue in tx, and links or copies msgFile to their
+// message files, see Add. The paths of the new message files are returned, the
+// caller must remove them with removeAddedFiles if the transaction is not
+// committed. The caller must kick the queue after committing.
+func addTx(log mlog.Log, tx *bstore.Tx, senderAccount string, msgFile *os.File, qml ...Msg) (rpaths []string, rerr error) {
 	if len(qml) == 0 {
-		return fmt.Errorf("must queue at least one message")
+		return nil, fmt.Errorf("must queue at least one message")
 	}
 
 	base := true
 
 	for i, qm := range qml {
 		if qm.ID != 0 {
-			return fmt.Errorf("id of queued messages must be 0")
+			return nil, fmt.Errorf("id of queued messages must be 0")
 		}
 		// Sanity check, internal consistency.
 		qml[i].SenderDomainStr = formatIPDomain(qm.SenderDomain)
@@ -647,22 +876,10 @@ func Add(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.Fi
 		}
 	}
 
-	tx, err := DB.Begin(ctx, true)
-	if err != nil {
-		return fmt.Errorf("begin transaction: %w", err)
-	}
-	defer func() {
-		if tx != nil {
-			if err := tx.Rollback(); err != nil {
-				log.Errorx("rollback for queue", err)
-			}
-		}
-	}()
-
 	// Mark messages Hold if they match a hold rule.
 	holdRules, err := bstore.QueryTx[HoldRule](tx).List()
 	if err != nil {
-		return fmt.Errorf("getting queue hold rules")
+		return nil, fmt.Errorf("getting queue hold rules")
 	}
 
 	// Insert messages into queue. If multiple messages are to be delivered in a single
@@ -675,14 +892,14 @@ func Add(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.Fi
 		// for uniquely identifying a message sent in the past.
 		if fromID := qml[i].FromID; fromID != "" {
 			if exists, err := bstore.QueryTx[Msg](tx).FilterNonzero(Msg{FromID: fromID}).Exists(); err != nil {
-				return fmt.Errorf("looking up fromid: %v", err)
+				return nil, fmt.Errorf("looking up fromid: %v", err)
 			} else if exists {
-				return fmt.Errorf("%w: fromid %q already present in message queue", ErrFromID, fromID)
+				return nil, fmt.Errorf("%w: fromid %q already present in message queue", ErrFromID, fromID)
 			}
 			if exists, err := bstore.QueryTx[MsgRetired](tx).FilterNonzero(MsgRetired{FromID: fromID}).Exists(); err != nil {
-				return fmt.Errorf("looking up fromid: %v", err)
+				return nil, fmt.Errorf("looking up fromid: %v", err)
 			} else if exists {
-				return fmt.Errorf("%w: fromid %q already present in retired message queue", ErrFromID, fromID)
+				return nil, fmt.Errorf("%w: fromid %q already present in retired message queue", ErrFromID, fromID)
 			}
 		}
 
@@ -695,22 +912,21 @@ func Add(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.Fi
 			}
 		}
 		if err := tx.Insert(&qml[i]); err != nil {
-			return err
+			return nil, err
 		}
 		if base && i == 0 && len(qml) > 1 {
 			baseID = qml[i].ID
 			qml[i].BaseID = baseID
 			if err := tx.Update(&qml[i]); err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
 	var paths []string
 	defer func() {
-		for _, p := range paths {
-			err := os.Remove(p)
-			log.Check(err, "removing destination message file for queue", slog.String("path", p))
+		if rerr != nil {
+			removeAddedFiles(log, paths)
 		}
 	}()
 
@@ -727,34 +943,35 @@ func Add(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.Fi
 		}
 
 		if err := moxio.LinkOrCopy(log, dst, msgFile.Name(), nil, true); err != nil {
-			return fmt.Errorf("linking/copying message to new file: %s", err)
+			return nil, fmt.Errorf("linking/copying message to new file: %s", err)
 		}
 	}
 
 	for dir := range syncDirs {
 		if err := moxio.SyncDir(log, dir); err != nil {
-			return fmt.Errorf("sync directory: %v", err)
+			return nil, fmt.Errorf("sync directory: %v", err)
 		}
 	}
 
 	for _, m := range qml {
 		if m.Hold {
 			if err := metricHoldUpdate(tx); err != nil {
-				return err
+				return nil, err
 			}
 			break
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("commit transaction: %s", err)
-	}
-	tx = nil
-	paths = nil
-
-	msgqueueKick()
+	return paths, nil
+}
 
-	return nil
+// removeAddedFiles removes message files created by addTx, after failing to
+// commit the transaction.
+func removeAddedFiles(log mlog.Log, paths []string) {
+	for _, p := range paths {
+		err := os.Remove(p)
+		log.Check(err, "removing destination message file for queue", slog.String("path", p))
+	}
 }
 
 func formatIPDomain(d dns.IPDomain) string {
@@ -824,14 +1041,15 @@ func NextAttemptSet(ctx context.Context, filter Filter, t time.Time) (affected i
 	return n, nil
 }
 
-// HoldSet sets Hold for all matching messages and kicks the queue.
+// HoldSet sets Hold for all matching messages and kicks the queue. Messages put
+// on hold are marked HoldManual, so resuming a hold rule keeps them on hold.
 func HoldSet(ctx context.Context, filter Filter, hold bool) (affected int, err error) {
 	err = DB.Write(ctx, func(tx *bstore.Tx) error {
 		q := bstore.QueryTx[Msg](tx)
 		if err := filter.apply(q); err != nil {
 			return err
 		}
-		n, err := q.UpdateFields(map[string]any{"Hold": hold})
+		n, err := q.UpdateFields(map[string]any{"Hold": hold, "HoldManual": hold})
 		if err != nil {
 			return fmt.Errorf("selecting and updating messages in queue: %v", err)
 		}
@@ -877,6 +1095,57 @@ func Drop(ctx context.Context, log mlog.Log, f Filter) (affected int, err error)
 	return failDrop(ctx, log, f, false)
 }
 
+// ErrCancelStarted is returned by Cancel when delivery of a message has already
+// started or the message is no longer in the queue.
+var ErrCancelStarted = errors.New("delivery already started")
+
+// Cancel removes the messages with ids submitted by senderAccount from the queue,
+// for undoing a submission that is held back for a short while. Either all
+// messages are removed, or, when a delivery attempt was made for any of them or
+// any is no longer in the queue, none are and ErrCancelStarted is returned.
+// Messages are added as retired message, webhooks with the "canceled" event are
+// queued.
+func Cancel(ctx context.Context, log mlog.Log, senderAccount string, ids []int64) error {
+	var msgs []Msg
+	err := DB.Write(ctx, func(tx *bstore.Tx) error {
+		q := bstore.QueryTx[Msg](tx)
+		q.FilterIDs(ids)
+		q.FilterNonzero(Msg{SenderAccount: senderAccount})
+		var err error
+		msgs, err = q.List()
+		if err != nil {
+			return fmt.Errorf("getting messages to cancel: %v", err)
+		}
+		if len(msgs) != len(ids) {
+			return ErrCancelStarted
+		}
+		now := time.Now()
+		for i := range msgs {
+			if msgs[i].LastAttempt != nil {
+				return ErrCancelStarted
+			}
+			msgs[i].Results = append(msgs[i].Results, MsgResult{Start: now, Error: "delivery canceled by sender"})
+		}
+		if len(msgs) == 0 {
+			return nil
+		}
+		if err := retireMsgs(log, tx, webhook.EventCanceled, 0, "", nil, msgs...); err != nil {
+			return fmt.Errorf("removing queue messages from database: %w", err)
+		}
+		return metricHoldUpdate(tx)
+	})
+	if err != nil {
+		return err
+	}
+	if len(msgs) > 0 {
+		if err := removeMsgsFS(log, msgs...); err != nil {
+			return fmt.Errorf("removing queue messages from file system: %w", err)
+		}
+	}
+	kick()
+	return nil
+}
+
 func failDrop(ctx context.Context, log mlog.Log, filter Filter, fail bool) (affected int, err error) {
 	var msgs []Msg
 	err = DB.Write(ctx, func(tx *bstore.Tx) error {
@@ -1105,6 +1374,16 @@ func OpenMessage(ctx context.Context, id int64) (ReadReaderAtCloser, error) {
 }
 
 const maxConcurrentDeliveries = 10
+
+// Delivery slots only used for messages with a priority above normal, so a backlog
+// of regular deliveries doesn't delay high priority messages.
+const reservedPriorityDeliveries = 2
+
+// regularDeliveriesFull returns whether, with "busy" deliveries in progress, no
+// more deliveries can be started for messages with normal or lower priority.
+func regularDeliveriesFull(busy int) bool {
+	return busy >= maxConcurrentDeliveries-reservedPriorityDeliveries
+}
 const maxConcurrentHookDeliveries = 10
 
 // Start opens the database by calling Init, then starts the delivery and cleanup
@@ -1145,6 +1424,7 @@ func cleanupMsgRetired(done chan struct{}) {
 		}
 
 		cleanupMsgRetiredSingle(log)
+		cleanupMsgDeadSingle(log)
 		timer.Reset(time.Hour)
 	}
 }
@@ -1173,6 +1453,7 @@ func startQueue(resolver dns.Resolver, done chan struct{}) {
 				domain := <-deliveryResults
 				delete(busyDomains, domain)
 			}
+			connPoolCloseAll(log)
 			done <- struct{}{}
 			return
 		case <-msgqueue:
@@ -1200,6 +1481,9 @@ func nextWork(ctx context.Context, log mlog.Log, busyDomains map[string]struct{}
 		q.FilterNotEqual("RecipientDomainStr", doms...)
 	}
 	q.FilterEqual("Hold", false)
+	if regularDeliveriesFull(len(busyDomains)) {
+		q.FilterGreater("Priority", 0)
+	}
 	q.SortAsc("NextAttempt")
 	q.Limit(1)
 	qm, err := q.Get()
@@ -1216,6 +1500,9 @@ func launchWork(log mlog.Log, resolver dns.Resolver, busyDomains map[string]stru
 	q := bstore.QueryDB[Msg](mox.Shutdown, DB)
 	q.FilterLessEqual("NextAttempt", time.Now())
 	q.FilterEqual("Hold", false)
+	// Of the due messages, those with a higher MT-PRIORITY go first. Some delivery
+	// slots are reserved for messages with a priority above normal.
+	q.SortDesc("Priority")
 	q.SortAsc("NextAttempt")
 	q.Limit(maxConcurrentDeliveries)
 	if len(busyDomains) > 0 {
@@ -1228,6 +1515,12 @@ func launchWork(log mlog.Log, resolver dns.Resolver, busyDomains map[string]stru
 	var msgs []Msg
 	seen := map[string]bool{}
 	err := q.ForEach(func(m Msg) error {
+		// Messages are sorted by priority, so once the regular slots are full, only
+		// messages we cannot start remain.
+		n := len(busyDomains) + len(msgs)
+		if n >= maxConcurrentDeliveries || m.Priority <= 0 && regularDeliveriesFull(n) {
+			return bstore.StopForEach
+		}
 		dom := m.RecipientDomainStr
 		if _, ok := busyDomains[dom]; !ok && !seen[dom] {
 			seen[dom] = true
@@ -1285,7 +1578,7 @@ func retireMsgs(log mlog.Log, tx *bstore.Tx, event webhook.OutgoingEvent, code i
 	if hookURL != "" && (len(accConf.OutgoingWebhook.Events) == 0 || slices.Contains(accConf.OutgoingWebhook.Events, string(event))) {
 		for _, m := range msgs {
 			suppressing := slices.Contains(suppressedMsgIDs, m.ID)
-			h, err := hookCompose(m, hookURL, accConf.OutgoingWebhook.Authorization, event, suppressing, code, secode)
+			h, err := hookCompose(m, *accConf.OutgoingWebhook, event, suppressing, code, secode)
 			if err != nil {
 				log.Errorx("composing webhooks while retiring messages from queue, not queueing hook for message", err, slog.Int64("msgid", m.ID), slog.Any("recipient", m.Recipient()))
 			} else {
@@ -1369,28 +1662,26 @@ func deliver(log mlog.Log, resolver dns.Resolver, m0 Msg) {
 	// already setting NextAttempt in the future with exponential backoff. If we run
 	// into trouble delivery below, at least we won't be bothering the receiving server
 	// with our problems.
-	// Delivery attempts: immediately, 7.5m, 15m, 30m, 1h, 2h (send delayed DSN), 4h,
-	// 8h, 16h (send permanent failure DSN).
-	// ../rfc/5321:3703
-	// todo future: make the back off times configurable. ../rfc/5321:3713
+	// The intervals come from the first matching configured retry schedule, or the
+	// default schedule. ../rfc/5321:3713
 	now := time.Now()
 	var backoff time.Duration
 	var origNextAttempt time.Time
 	prepare := func() error {
-		// Refresh message within transaction.
-		m0 = Msg{ID: m0.ID}
-		if err := xtx.Get(&m0); err != nil {
+		// Refresh message within transaction. Into a separate variable, m0 is used for
+		// the delivery result, also on error.
+		m := Msg{ID: m0.ID}
+		if err := xtx.Get(&m); err != nil {
 			return fmt.Errorf("get message to be delivered: %v", err)
 		}
+		m0 = m
 
-		backoff = time.Duration(7*60+30+jitter.IntN(10)-5) * time.Second
-		for range m0.Attempts {
-			backoff *= time.Duration(2)
-		}
+		rs := findRetrySchedule(m0)
+		backoff = retryBackoff(rs, m0.Attempts) + time.Duration(jitter.IntN(10)-5)*time.Second
 		m0.Attempts++
 		origNextAttempt = m0.NextAttempt
 		m0.LastAttempt = &now
-		m0.NextAttempt = now.Add(backoff)
+		m0.NextAttempt = retryNextAttempt(rs, m0, now, backoff)
 		m0.Results = append(m0.Results, MsgResult{Start: now, Error: resultErrorDelivering})
 		if err := xtx.Update(&m0); err != nil {
 			return fmt.Errorf("update message to be delivered: %v", err)
@@ -1404,11 +1695,18 @@ func deliver(log mlog.Log, resolver dns.Resolver, m0 Msg) {
 
 	var remoteMTA dsn.NameIP // Zero value, will not be included in DSN. ../rfc/3464:1027
 
+	// Message files to remove or move to the dead-letter queue after a permanent
+	// failure, see failMsgsTx.
+	var remove, dead []Msg
+
 	// If domain of sender is currently disabled, fail the delivery attempt.
 	if domConf, _ := mox.Conf.Domain(m0.SenderDomain.Domain); domConf.Disabled {
-		failMsgsTx(qlog, xtx, []*Msg{&m0}, m0.DialedIPs, backoff, remoteMTA, fmt.Errorf("domain of sender temporarily disabled"))
+		remove, dead = failMsgsTx(qlog, xtx, []*Msg{&m0}, m0.DialedIPs, backoff, remoteMTA, fmt.Errorf("domain of sender temporarily disabled"))
 		err = xtx.Commit()
 		qlog.Check(err, "commit processing failure to deliver messages")
+		if err == nil {
+			failMsgsFS(qlog, remove, dead)
+		}
 		xtx = nil
 		kick()
 		return
@@ -1426,10 +1724,13 @@ func deliver(log mlog.Log, resolver dns.Resolver, m0 Msg) {
 		} else {
 			err := fmt.Errorf("not delivering to recipient address %s: %w", path.XString(true), errSuppressed)
 			err = smtpclient.Error{Permanent: true, Err: err}
-			failMsgsTx(qlog, xtx, []*Msg{&m0}, m0.DialedIPs, backoff, remoteMTA, err)
+			remove, dead = failMsgsTx(qlog, xtx, []*Msg{&m0}, m0.DialedIPs, backoff, remoteMTA, err)
 		}
 		err = xtx.Commit()
 		qlog.Check(err, "commit processing failure to deliver messages")
+		if err == nil {
+			failMsgsFS(qlog, remove, dead)
+		}
 		xtx = nil
 		kick()
 		return
@@ -1452,9 +1753,12 @@ func deliver(log mlog.Log, resolver dns.Resolver, m0 Msg) {
 	transportName, transport, transportOK := resolveTransport(m0)
 	m0.Attempts++
 	if !transportOK {
-		failMsgsTx(qlog, xtx, []*Msg{&m0}, m0.DialedIPs, backoff, remoteMTA, fmt.Errorf("cannot find transport %q", m0.Transport))
+		remove, dead = failMsgsTx(qlog, xtx, []*Msg{&m0}, m0.DialedIPs, backoff, remoteMTA, fmt.Errorf("cannot find transport %q", m0.Transport))
 		err = xtx.Commit()
 		qlog.Check(err, "commit processing failure to deliver messages")
+		if err == nil {
+			failMsgsFS(qlog, remove, dead)
+		}
 		xtx = nil
 		kick()
 		return
@@ -9,6 +9,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -76,6 +77,7 @@ func setup(t *testing.T) (*store.Account, func()) {
 	tcheck(t, err, "set password")
 
 	return acc, func() {
+		connPoolCloseAll(log)
 		acc.Close()
 		acc.WaitClosed()
 		mox.ShutdownCancel()
@@ -171,6 +173,31 @@ func TestQueue(t *testing.T) {
 		t.Fatalf("dropped message not removed from file system")
 	}
 
+	// Cancel a message by its sender, e.g. for undoing send.
+	qm = MakeMsg(path, path, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	err = Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message to queue for delivery")
+	qmc, err := bstore.QueryDB[Msg](ctxbg, DB).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get message")
+	err = Cancel(ctxbg, pkglog, "other", []int64{qmc.ID})
+	tcompare(t, errors.Is(err, ErrCancelStarted), true) // Not the sender.
+	now := time.Now()
+	qmc.LastAttempt = &now
+	err = DB.Update(ctxbg, &qmc)
+	tcheck(t, err, "update message")
+	err = Cancel(ctxbg, pkglog, "mjl", []int64{qmc.ID})
+	tcompare(t, errors.Is(err, ErrCancelStarted), true) // Delivery already started.
+	qmc.LastAttempt = nil
+	err = DB.Update(ctxbg, &qmc)
+	tcheck(t, err, "update message")
+	err = Cancel(ctxbg, pkglog, "mjl", []int64{qmc.ID})
+	tcheck(t, err, "cancel message")
+	if _, err := os.Stat(qmc.MessagePath()); err == nil || !os.IsNotExist(err) {
+		t.Fatalf("canceled message not removed from file system")
+	}
+	err = Cancel(ctxbg, pkglog, "mjl", []int64{qmc.ID})
+	tcompare(t, errors.Is(err, ErrCancelStarted), true) // No longer in queue.
+
 	// Fail a message, check the account has a message afterwards, the DSN.
 	n, err = bstore.QueryDB[store.Message](ctxbg, acc.DB).Count()
 	tcheck(t, err, "count messages in account")
@@ -1012,6 +1039,22 @@ func TestRetiredHooks(t *testing.T) {
 			lr.Duration = 0
 			tcompare(t, lr.Error == "", expResult.Error == "")
 			lr.Error = expResult.Error
+			// Check SMTP session details, if remote was reached.
+			session := lr
+			if expResult.Code != 0 {
+				tcompare(t, lr.RemoteMTA != "", true)
+				tcompare(t, len(lr.Response) > 0 && strings.HasPrefix(lr.Response[0], fmt.Sprintf("%d ", expResult.Code)), true)
+				tcompare(t, strings.Contains(lr.Transcript, "C: MAIL FROM:<mjl@mox.example>"), true)
+				// Resolver responses are not authentic, so no DANE.
+				tcompare(t, lr.DANEStatus, "insecure")
+				tcompare(t, slices.Contains(lr.DNSLookups, dns.Lookup{Type: "mx", Name: "mox.example."}), true)
+			}
+			lr.RemoteMTA = ""
+			lr.Response = nil
+			lr.TLS = ""
+			lr.Transcript = ""
+			lr.DNSLookups = nil
+			lr.DANEStatus = ""
 			tcompare(t, lr, *expResult)
 
 			// Compare added webhook.
@@ -1043,6 +1086,20 @@ func TestRetiredHooks(t *testing.T) {
 				SMTPCode:         code,
 				SMTPEnhancedCode: ecode,
 				Extra:            mr.Extra,
+				Recipient:        "mjl@mox.example",
+				Attempts:         mr.Attempts,
+				RemoteMTA:        session.RemoteMTA,
+				SMTPResponse:     session.Response,
+				TLS:              session.TLS,
+			}
+			switch expResult.Secode {
+			case "0.0":
+				expOut.BounceClass = webhook.BouncePolicy
+			case "1.0":
+				expOut.BounceClass = webhook.BounceHard
+			}
+			if expOut.SMTPResponse == nil {
+				expOut.SMTPResponse = []string{}
 			}
 			tcompare(t, out, expOut)
 			h.ID = 0
@@ -1110,7 +1167,7 @@ func TestRetiredHooks(t *testing.T) {
 		readline("quit")
 		writeline("250 ok")
 	}
-	smtpReject := func(code int) func(conn net.Conn) {
+	smtpReject := func(code int, secode string) func(conn net.Conn) {
 		return func(conn net.Conn) {
 			br := bufio.NewReader(conn)
 			readline := func(cmd string) {
@@ -1129,7 +1186,7 @@ func TestRetiredHooks(t *testing.T) {
 			writeline("250 enhancedstatuscodes")
 
 			readline("mail")
-			writeline(fmt.Sprintf("%d 5.1.0 nok", code))
+			writeline(fmt.Sprintf("%d 5.%s nok", code, secode))
 			readline("quit")
 			writeline("250 ok")
 		}
@@ -1138,11 +1195,11 @@ func TestRetiredHooks(t *testing.T) {
 	testAction("mjl", makeLaunchAction(smtpAccept), nil, "", false)
 	testAction("retired", makeLaunchAction(smtpAccept), &MsgResult{Code: 250, Success: true}, string(webhook.EventDelivered), false)
 	// 554 is generic, doesn't immediately cause suppression.
-	testAction("mjl", makeLaunchAction(smtpReject(554)), nil, "", false)
-	testAction("retired", makeLaunchAction(smtpReject(554)), &MsgResult{Code: 554, Secode: "1.0", Error: "nonempty"}, string(webhook.EventFailed), false)
+	testAction("mjl", makeLaunchAction(smtpReject(554, "0.0")), nil, "", false)
+	testAction("retired", makeLaunchAction(smtpReject(554, "0.0")), &MsgResult{Code: 554, Secode: "0.0", Error: "nonempty"}, string(webhook.EventFailed), false)
 	// 550 causes immediate suppression, check for it in webhook.
-	testAction("mjl", makeLaunchAction(smtpReject(550)), nil, "", true)
-	testAction("retired", makeLaunchAction(smtpReject(550)), &MsgResult{Code: 550, Secode: "1.0", Error: "nonempty"}, string(webhook.EventFailed), true)
+	testAction("mjl", makeLaunchAction(smtpReject(550, "1.0")), nil, "", true)
+	testAction("retired", makeLaunchAction(smtpReject(550, "1.0")), &MsgResult{Code: 550, Secode: "1.0", Error: "nonempty"}, string(webhook.EventFailed), true)
 	// Try to deliver to suppressed addresses.
 	launch := func() {
 		n := launchWork(pkglog, resolver, map[string]struct{}{})
@@ -1230,6 +1287,36 @@ func TestQueueStart(t *testing.T) {
 		}
 	}
 
+	// waitIdle waits until no messages are due for delivery and no deliveries are
+	// in progress, so the test doesn't continue or stop the queue with deliveries
+	// still running.
+	waitIdle := func() {
+		t.Helper()
+		for i := 0; ; i++ {
+			// Further dials may be needed for the messages.
+			select {
+			case <-dialed:
+			default:
+			}
+			l, err := List(ctxbg, Filter{}, Sort{})
+			tcheck(t, err, "list messages")
+			var busy int
+			now := time.Now()
+			for _, m := range l {
+				if !m.Hold && !m.NextAttempt.After(now) || len(m.Results) > 0 && m.Results[len(m.Results)-1].Error == resultErrorDelivering {
+					busy++
+				}
+			}
+			if busy == 0 {
+				return
+			}
+			if i == 100 {
+				t.Fatalf("%d messages still due or being delivered", busy)
+			}
+			time.Sleep(time.Second / 50)
+		}
+	}
+
 	// HoldRule to mark mark all messages sent by mjl on hold, including existing
 	// messages.
 	hr0, err := HoldRuleAdd(ctxbg, pkglog, HoldRule{Account: "mjl"})
@@ -1293,6 +1380,145 @@ func TestQueueStart(t *testing.T) {
 	err = Add(ctxbg, pkglog, "mjl", mf, qm)
 	tcheck(t, err, "add message to queue for delivery")
 	checkDialed(true) // Immediate.
+
+	// Pause deliveries to the recipient domain and from the account. Resuming the
+	// recipient domain rule should leave the message on hold because of the account
+	// rule. Resuming the account rule takes it off hold.
+	hr2, err := HoldRuleAdd(ctxbg, pkglog, HoldRule{RecipientDomain: dns.Domain{ASCII: "mox.example"}})
+	tcheck(t, err, "add hold rule")
+	hr3, err := HoldRuleAdd(ctxbg, pkglog, HoldRule{Account: "mjl"})
+	tcheck(t, err, "add hold rule")
+	qm = MakeMsg(path, path, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	err = Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message to queue for delivery")
+	checkDialed(false)
+
+	n, err = HoldRuleResume(ctxbg, pkglog, hr2.ID)
+	tcheck(t, err, "resume hold rule")
+	tcompare(t, n, 0)
+	checkDialed(false)
+
+	// Earlier messages failed delivery and are still in the queue, and on hold too.
+	n, err = HoldRuleResume(ctxbg, pkglog, hr3.ID)
+	tcheck(t, err, "resume hold rule")
+	tcompare(t, n, 3)
+	hrl, err = HoldRuleList(ctxbg)
+	tcheck(t, err, "listing hold rules")
+	tcompare(t, len(hrl), 0)
+	_, err = NextAttemptSet(ctxbg, Filter{}, time.Now())
+	tcheck(t, err, "schedule message")
+	checkDialed(true)
+
+	_, err = HoldRuleResume(ctxbg, pkglog, hr3.ID)
+	if err == nil {
+		t.Fatalf("resuming removed hold rule succeeded")
+	}
+
+	// Messages put on hold explicitly stay on hold when a matching hold rule is
+	// resumed.
+	n, err = HoldSet(ctxbg, Filter{}, true)
+	tcheck(t, err, "put messages on hold")
+	tcompare(t, n, 3)
+	hr4, err := HoldRuleAdd(ctxbg, pkglog, HoldRule{Account: "mjl"})
+	tcheck(t, err, "add hold rule")
+	n, err = HoldRuleResume(ctxbg, pkglog, hr4.ID)
+	tcheck(t, err, "resume hold rule")
+	tcompare(t, n, 0)
+	n, err = HoldSet(ctxbg, Filter{}, false)
+	tcheck(t, err, "take messages off hold")
+	tcompare(t, n, 3)
+	checkDialed(true)
+	waitIdle()
+
+	// All conditions of a hold rule must match for new messages to be held, not just
+	// one of them.
+	hr5, err := HoldRuleAdd(ctxbg, pkglog, HoldRule{Account: "mjl", RecipientDomain: dns.Domain{ASCII: "other.example"}})
+	tcheck(t, err, "add hold rule")
+	qm = MakeMsg(path, path, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, time.Now(), "test")
+	err = Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message to queue for delivery")
+	checkDialed(true)
+	held, err := List(ctxbg, Filter{Hold: &[]bool{true}[0]}, Sort{})
+	tcheck(t, err, "list messages on hold")
+	tcompare(t, len(held), 0)
+	err = HoldRuleRemove(ctxbg, pkglog, hr5.ID)
+	tcheck(t, err, "removing hold rule")
+	waitIdle()
+}
+
+// Test that higher priority messages are delivered first, and can use delivery
+// slots that regular messages cannot.
+func TestLaunchWorkPriority(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	smtpclient.DialHook = func(ctx context.Context, dialer smtpclient.Dialer, timeout time.Duration, addr string, laddr net.Addr) (net.Conn, error) {
+		return nil, fmt.Errorf("failure from test")
+	}
+	defer func() {
+		smtpclient.DialHook = nil
+	}()
+
+	mf := prepareFile(t)
+	defer os.Remove(mf.Name())
+	defer mf.Close()
+
+	// Two regular messages queued first, then two with a higher priority.
+	now := time.Now()
+	add := func(domain string, priority int, queued time.Duration) {
+		t.Helper()
+		path := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: domain}}}
+		qm := MakeMsg(path, path, false, false, int64(len(testmsg)), "<test@localhost>", nil, nil, now.Add(queued), "test")
+		qm.Priority = priority
+		err := Add(ctxbg, pkglog, "mjl", mf, qm)
+		tcheck(t, err, "add message to queue")
+	}
+	add("a.example", 0, -4*time.Minute)
+	add("b.example", -1, -3*time.Minute)
+	add("c.example", 1, -2*time.Minute)
+	add("d.example", 5, -time.Minute)
+
+	launch := func(busy map[string]struct{}, expDomains ...string) {
+		t.Helper()
+		n := launchWork(pkglog, dns.MockResolver{}, busy)
+		tcompare(t, n, len(expDomains))
+		for _, dom := range expDomains {
+			if _, ok := busy[dom]; !ok {
+				t.Fatalf("no delivery started for %s", dom)
+			}
+		}
+		timer := time.NewTimer(5 * time.Second)
+		defer timer.Stop()
+		for range n {
+			select {
+			case dom := <-deliveryResults:
+				delete(busy, dom)
+			case <-timer.C:
+				t.Fatalf("no delivery result")
+			}
+		}
+	}
+
+	// With the slots for regular deliveries in use, only the reserved slots can be
+	// used, by the higher priority messages.
+	busy := map[string]struct{}{}
+	for i := range maxConcurrentDeliveries - reservedPriorityDeliveries {
+		busy[fmt.Sprintf("busy%d.example", i)] = struct{}{}
+	}
+	launch(busy, "c.example", "d.example")
+	// The due regular messages are not considered for the next work, only the
+	// rescheduled higher priority messages.
+	if x := nextWork(ctxbg, pkglog, busy); x <= 0 {
+		t.Fatalf("nextWork in %s with regular deliveries busy, should be later", x)
+	}
+
+	// With one slot for regular deliveries left, only the first queued is started.
+	delete(busy, "busy0.example")
+	if x := nextWork(ctxbg, pkglog, busy); x > 0 {
+		t.Fatalf("nextWork in %s, should be now", x)
+	}
+	launch(busy, "a.example")
+	launch(map[string]struct{}{}, "b.example")
 }
 
 func TestListFilterSort(t *testing.T) {
@@ -1462,6 +1688,38 @@ func TestListFilterSort(t *testing.T) {
 	lr, err = RetiredList(ctxbg, allretiredfilters, RetiredSort{})
 	tcheck(t, err, "list single")
 	tcompare(t, lr, []MsgRetired{mrlrev[0]})
+
+	// Search by domain and last error, and get transcript.
+	qm1.Results = []MsgResult{
+		{Error: "old error"},
+		{Error: "451 Greylisted, try again later", Transcript: "S: 220 mox.example\n"},
+	}
+	err = DB.Update(ctxbg, &qm1)
+	tcheck(t, err, "update message")
+	n, err := CountFilter(ctxbg, Filter{Max: 1, Domain: "MOX.example"})
+	tcheck(t, err, "count")
+	tcompare(t, n, len(qml))
+	n, err = CountFilter(ctxbg, Filter{Domain: ".example"})
+	tcheck(t, err, "count")
+	tcompare(t, n, len(qml))
+	n, err = CountFilter(ctxbg, Filter{Domain: "other.example"})
+	tcheck(t, err, "count")
+	tcompare(t, n, 0)
+	l, err = List(ctxbg, Filter{LastError: "greylisted"}, Sort{})
+	tcheck(t, err, "list by last error")
+	tcompare(t, len(l), 1)
+	tcompare(t, l[0].ID, qm1.ID)
+	n, err = CountFilter(ctxbg, Filter{LastError: "old error"})
+	tcheck(t, err, "count")
+	tcompare(t, n, 0)
+	r, err := Transcript(ctxbg, qm1.ID)
+	tcheck(t, err, "transcript")
+	tcompare(t, r.Transcript, "S: 220 mox.example\n")
+	r, err = Transcript(ctxbg, qml[0].ID)
+	tcheck(t, err, "transcript")
+	tcompare(t, r, MsgResult{})
+	_, err = Transcript(ctxbg, qm1.ID+100)
+	tcompare(t, err, bstore.ErrAbsent)
 }
 
 // Just a cert that appears valid.
@@ -0,0 +1,90 @@
+package queue
+
+import (
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+)
+
+// Default retry schedule, used when no configured schedule matches. Delivery
+// attempts: immediately, 7.5m, 15m, 30m, 1h, 2h (send delayed DSN), 4h, 8h
+// (send permanent failure DSN). ../rfc/5321:3703
+const (
+	defaultRetryInterval    = 7*time.Minute + 30*time.Second
+	defaultRetryMaxAttempts = 8
+	delayedDSNAttempt       = 5
+)
+
+// findRetrySchedule returns the first configured retry schedule matching the
+// message. If none match, a zero schedule is returned, causing defaults to be used.
+func findRetrySchedule(m Msg) config.RetrySchedule {
+	class := "regular"
+	if m.IsDMARCReport || m.IsTLSReport {
+		class = "report"
+	}
+	for _, rs := range mox.Conf.RetrySchedules() {
+		if (rs.Class == "" || rs.Class == class) && (rs.MinPriority == 0 || m.Priority >= rs.MinPriority) && (rs.MaxPriority == 0 || m.Priority <= rs.MaxPriority) && routeMatchDomain(rs.ToDomainASCII, m.RecipientDomain.Domain) {
+			return rs
+		}
+	}
+	return config.RetrySchedule{}
+}
+
+// retryBackoff returns the time to wait before the next attempt, after a
+// failed delivery attempt for which "attempts" previous attempts were made. Without
+// jitter.
+func retryBackoff(rs config.RetrySchedule, attempts int) time.Duration {
+	if len(rs.Intervals) > 0 {
+		return rs.Intervals[min(attempts, len(rs.Intervals)-1)]
+	}
+	backoff := defaultRetryInterval
+	for range attempts {
+		backoff *= 2
+	}
+	return backoff
+}
+
+// retryMaxAttempts returns the number of attempts after which delivery of the
+// message fails permanently.
+func retryMaxAttempts(rs config.RetrySchedule, m Msg) int {
+	if m.MaxAttempts > 0 {
+		return m.MaxAttempts
+	} else if rs.MaxAttempts > 0 {
+		return rs.MaxAttempts
+	}
+	return defaultRetryMaxAttempts
+}
+
+// retryExhausted returns whether no further delivery attempts should be made for
+// the message, which has just had a failed delivery attempt.
+func retryExhausted(rs config.RetrySchedule, m Msg, now time.Time) bool {
+	return m.Attempts >= retryMaxAttempts(rs, m) || rs.MaxAge > 0 && !now.Before(m.Queued.Add(rs.MaxAge))
+}
+
+// retryNextAttempt returns the time for the next delivery attempt after an
+// attempt at "now", limited by the maximum age of the schedule.
+func retryNextAttempt(rs config.RetrySchedule, m Msg, now time.Time, backoff time.Duration) time.Time {
+	next := now.Add(backoff)
+	if rs.MaxAge > 0 {
+		if end := m.Queued.Add(rs.MaxAge); next.After(end) {
+			next = end
+		}
+	}
+	return next
+}
+
+// retryUntil returns the time of the last delivery attempt that will be made
+// after the current failed attempt at "last", for use in a delayed DSN.
+func retryUntil(rs config.RetrySchedule, m Msg, last time.Time) time.Time {
+	t := last
+	for n := m.Attempts - 1; n < retryMaxAttempts(rs, m)-1; n++ {
+		t = t.Add(retryBackoff(rs, n))
+	}
+	if rs.MaxAge > 0 {
+		if end := m.Queued.Add(rs.MaxAge); t.After(end) {
+			t = end
+		}
+	}
+	return t
+}
@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestRetrySchedule(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	mox.Conf.Dynamic.RetrySchedules = []config.RetrySchedule{
+		{
+			MinPriority: 5,
+			Intervals:   []time.Duration{30 * time.Second},
+			MaxAttempts: 30,
+		},
+		{
+			MaxPriority: -1,
+			Intervals:   []time.Duration{time.Hour},
+		},
+		{
+			ToDomainASCII: []string{".partner.example"},
+			Intervals:     []time.Duration{time.Minute, 2 * time.Minute},
+			MaxAttempts:   20,
+		},
+		{
+			Class:  "report",
+			MaxAge: 2 * time.Hour,
+		},
+	}
+	defer func() {
+		mox.Conf.Dynamic.RetrySchedules = nil
+	}()
+
+	now := time.Now()
+	msg := func(domain string, report bool) Msg {
+		return Msg{Queued: now, RecipientDomain: dns.IPDomain{Domain: dns.Domain{ASCII: domain}}, IsDMARCReport: report}
+	}
+
+	// Default schedule.
+	m := msg("other.example", false)
+	rs := findRetrySchedule(m)
+	tcompare(t, retryBackoff(rs, 0), 7*time.Minute+30*time.Second)
+	tcompare(t, retryBackoff(rs, 4), 2*time.Hour)
+	tcompare(t, retryMaxAttempts(rs, m), 8)
+	m.Attempts = 5
+	tcompare(t, retryUntil(rs, m, now), now.Add((2+4+8)*time.Hour))
+	m.Attempts = 8
+	tcompare(t, retryExhausted(rs, m, now), true)
+	m.MaxAttempts = 10
+	tcompare(t, retryExhausted(rs, m, now), false)
+
+	// Partner domain, including subdomains, is retried aggressively.
+	m = msg("mx.partner.example", false)
+	rs = findRetrySchedule(m)
+	tcompare(t, retryBackoff(rs, 0), time.Minute)
+	tcompare(t, retryBackoff(rs, 10), 2*time.Minute)
+	m.Attempts = 8
+	tcompare(t, retryExhausted(rs, m, now), false)
+	m.Attempts = 20
+	tcompare(t, retryExhausted(rs, m, now), true)
+
+	// Reports give up after max age, and last attempt is at max age.
+	m = msg("other.example", true)
+	rs = findRetrySchedule(m)
+	tcompare(t, retryBackoff(rs, 4), 2*time.Hour)
+	m.Attempts = 2
+	tcompare(t, retryNextAttempt(rs, m, now.Add(time.Hour), 4*time.Hour), now.Add(2*time.Hour))
+	tcompare(t, retryExhausted(rs, m, now.Add(time.Hour)), false)
+	tcompare(t, retryExhausted(rs, m, now.Add(2*time.Hour)), true)
+
+	// High priority messages, also to the partner domain, have their own schedule.
+	// Low priority messages are retried less often. Priorities in between use the
+	// other schedules.
+	m = msg("mx.partner.example", false)
+	m.Priority = 5
+	rs = findRetrySchedule(m)
+	tcompare(t, retryBackoff(rs, 3), 30*time.Second)
+	tcompare(t, retryMaxAttempts(rs, m), 30)
+	m.Priority = -3
+	rs = findRetrySchedule(m)
+	tcompare(t, retryBackoff(rs, 0), time.Hour)
+	m.Priority = 4
+	rs = findRetrySchedule(m)
+	tcompare(t, retryBackoff(rs, 0), time.Minute)
+}
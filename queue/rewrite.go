@@ -0,0 +1,308 @@
+package queue
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// MsgChange is a change made by an admin to a message in the queue, kept with
+// the message as audit trail.
+type MsgChange struct {
+	Time        time.Time
+	Description string // E.g. "recipient changed from ... to ...".
+}
+
+// MsgRewrite describes changes to a message in the queue, to be used for its
+// next delivery attempt. Only non-nil fields are changed.
+type MsgRewrite struct {
+	Recipient *string // Address used in RCPT TO.
+	Transport *string // Empty for the default transport based on routes.
+
+	// From header value, e.g. "Name <user@example.org>". When From or Subject is
+	// changed, DKIM-Signature header fields are removed and the message is signed
+	// again if the From domain has DKIM configured.
+	From    *string
+	Subject *string
+}
+
+// ErrRewrite is returned by Rewrite for changes that cannot be made, e.g.
+// invalid addresses or a message that is currently being delivered.
+var ErrRewrite = errors.New("cannot rewrite message")
+
+// Rewrite changes the envelope and/or headers of a message in the queue before its
+// next delivery attempt. Changes are added to the audit trail of the message.
+// A message with a changed header is no longer delivered together with other
+// recipients of the same message. The message is scheduled for immediate delivery,
+// unless on hold.
+func Rewrite(ctx context.Context, log mlog.Log, id int64, rw MsgRewrite) (rm Msg, rerr error) {
+	var rcpt smtp.Path
+	if rw.Recipient != nil {
+		addr, err := smtp.ParseAddress(*rw.Recipient)
+		if err != nil {
+			return Msg{}, fmt.Errorf("%w: parsing recipient: %v", ErrRewrite, err)
+		}
+		rcpt = addr.Path()
+	}
+	if rw.Transport != nil && *rw.Transport != "" {
+		if _, ok := mox.Conf.Static.Transports[*rw.Transport]; !ok {
+			return Msg{}, fmt.Errorf("%w: unknown transport %q", ErrRewrite, *rw.Transport)
+		}
+	}
+	var fromAddr *mail.Address
+	var fromSMTP smtp.Address
+	if rw.From != nil {
+		var err error
+		fromAddr, err = mail.ParseAddress(*rw.From)
+		if err != nil {
+			return Msg{}, fmt.Errorf("%w: parsing from address: %v", ErrRewrite, err)
+		}
+		fromSMTP, err = smtp.ParseAddress(fromAddr.Address)
+		if err != nil {
+			return Msg{}, fmt.Errorf("%w: parsing from address: %v", ErrRewrite, err)
+		}
+	}
+	if rw.Subject != nil && strings.ContainsAny(*rw.Subject, "\r\n") {
+		return Msg{}, fmt.Errorf("%w: subject cannot contain newlines", ErrRewrite)
+	}
+
+	// We hold the write transaction while rewriting the message file, to prevent a
+	// delivery attempt from starting with the old file.
+	var renamed string
+	err := DB.Write(ctx, func(tx *bstore.Tx) error {
+		m := Msg{ID: id}
+		if err := tx.Get(&m); err != nil {
+			return err
+		}
+		if len(m.Results) > 0 && m.Results[len(m.Results)-1].Error == resultErrorDelivering {
+			return fmt.Errorf("%w: delivery in progress, try again later", ErrRewrite)
+		}
+
+		now := time.Now()
+		change := func(format string, args ...any) {
+			desc := fmt.Sprintf(format, args...)
+			log.Info("rewriting message in queue", slog.Int64("msgid", m.ID), slog.String("change", desc))
+			m.Changes = append(m.Changes, MsgChange{now, desc})
+		}
+
+		if rw.Recipient != nil {
+			change("recipient changed from %s to %s", m.Recipient().XString(true), rcpt.XString(true))
+			m.RecipientLocalpart = rcpt.Localpart
+			m.RecipientDomain = rcpt.IPDomain
+			m.RecipientDomainStr = formatIPDomain(rcpt.IPDomain)
+			m.DialedIPs = nil
+		}
+		if rw.Transport != nil {
+			change("transport changed from %q to %q", m.Transport, *rw.Transport)
+			m.Transport = *rw.Transport
+		}
+
+		if rw.From != nil || rw.Subject != nil {
+			if m.DSNUTF8 != nil {
+				return fmt.Errorf("%w: cannot change headers of delivery status notification", ErrRewrite)
+			}
+			var subject string
+			if rw.Subject != nil {
+				subject = mime.QEncoding.Encode("utf-8", *rw.Subject)
+			}
+			var from string
+			if fromAddr != nil {
+				from = fromAddr.String()
+			}
+			p, err := rewriteMsgFile(ctx, log, &m, from, fromSMTP, subject)
+			if err != nil {
+				return err
+			}
+			renamed = p
+			if rw.From != nil {
+				change("from header changed to %s", from)
+				if fromSMTP.Localpart.IsInternational() || fromSMTP.Domain.Unicode != "" {
+					m.SMTPUTF8 = true
+					m.Has8bit = true
+				}
+			}
+			if rw.Subject != nil {
+				change("subject changed from %q to %q", m.Subject, *rw.Subject)
+				m.Subject = *rw.Subject
+			}
+			// Content is no longer identical to other recipients.
+			m.BaseID = 0
+		}
+
+		if len(m.Changes) == 0 {
+			return fmt.Errorf("%w: no changes", ErrRewrite)
+		}
+		m.NextAttempt = now
+		if err := tx.Update(&m); err != nil {
+			return fmt.Errorf("updating message in queue: %v", err)
+		}
+		rm = m
+		return nil
+	})
+	if err != nil {
+		if renamed != "" {
+			// The file was already replaced. The message cannot be delivered as it was
+			// before, but it is still a valid message.
+			log.Error("message file was rewritten but queue message not updated", slog.String("path", renamed))
+		}
+		return Msg{}, err
+	}
+	msgqueueKick()
+	return rm, nil
+}
+
+// rewriteMsgFile replaces the From and/or Subject header in the message file of m
+// (if non-empty), removes DKIM-Signature header fields, and adds new DKIM
+// signatures for the From domain. MsgPrefix and Size of m are updated. The message
+// file is replaced with a new file, so other hard links to the original file are
+// not affected.
+func rewriteMsgFile(ctx context.Context, log mlog.Log, m *Msg, from string, fromAddr smtp.Address, subject string) (path string, rerr error) {
+	f, err := os.Open(m.MessagePath())
+	if err != nil {
+		return "", fmt.Errorf("open message file: %v", err)
+	}
+	defer func() {
+		err := f.Close()
+		log.Check(err, "closing message file")
+	}()
+
+	br := bufio.NewReader(store.FileMsgReader(m.MsgPrefix, f))
+	hdr, err := message.ReadHeaders(br)
+	if err != nil {
+		return "", fmt.Errorf("%w: reading message header: %v", ErrRewrite, err)
+	}
+	nhdr := rewriteHeader(hdr, from, subject)
+
+	tmpf, err := store.CreateMessageTemp(log, "queue-rewrite")
+	if err != nil {
+		return "", fmt.Errorf("creating temporary file: %v", err)
+	}
+	defer func() {
+		if tmpf != nil {
+			store.CloseRemoveTempFile(log, tmpf, "rewritten queue message")
+		}
+	}()
+
+	if _, err := tmpf.Write(nhdr); err != nil {
+		return "", fmt.Errorf("writing message header: %v", err)
+	}
+	if _, err := tmpf.Write([]byte("\r\n")); err != nil {
+		return "", fmt.Errorf("writing message header separator: %v", err)
+	}
+	if _, err := io.Copy(tmpf, br); err != nil {
+		return "", fmt.Errorf("writing message body: %v", err)
+	}
+
+	// If the From domain is ours, sign again, like during submission.
+	if from == "" {
+		fromAddr, _, _, err = message.From(log.Logger, false, tmpf, nil)
+		if err != nil {
+			log.Debugx("parsing from address of rewritten message, not dkim signing", err)
+			fromAddr = smtp.Address{}
+		}
+	}
+	var prefix []byte
+	if confDom, ok := mox.Conf.Domain(fromAddr.Domain); ok && !confDom.Disabled {
+		selectors := mox.DKIMSelectorsMessage(log, confDom.DKIM, m.SenderAccount, fromAddr, tmpf)
+		if len(selectors) > 0 {
+			canonical := mox.CanonicalLocalpart(fromAddr.Localpart, confDom)
+			dkimHeaders, err := dkim.Sign(ctx, log.Logger, canonical, fromAddr.Domain, selectors, m.SMTPUTF8, tmpf)
+			if err != nil {
+				return "", fmt.Errorf("dkim signing rewritten message: %v", err)
+			}
+			prefix = []byte(dkimHeaders)
+		}
+	}
+
+	if err := tmpf.Sync(); err != nil {
+		return "", fmt.Errorf("sync message file: %v", err)
+	}
+	fi, err := tmpf.Stat()
+	if err != nil {
+		return "", fmt.Errorf("stat rewritten message: %v", err)
+	}
+	p := m.MessagePath()
+	if err := os.Rename(tmpf.Name(), p); err != nil {
+		return "", fmt.Errorf("replacing message file: %v", err)
+	}
+	err = tmpf.Close()
+	log.Check(err, "closing rewritten message file")
+	tmpf = nil
+	if err := moxio.SyncDir(log, filepath.Dir(p)); err != nil {
+		log.Errorx("sync queue directory after rewriting message", err)
+	}
+	m.MsgPrefix = prefix
+	m.Size = int64(len(prefix)) + fi.Size()
+	return p, nil
+}
+
+// rewriteHeader returns hdr with DKIM-Signature header fields removed and the From
+// and Subject header fields replaced with the values, if not empty. Header fields
+// that are not present are added.
+func rewriteHeader(hdr []byte, from, subject string) []byte {
+	var fields [][]byte
+	for len(hdr) > 0 {
+		// Find end of header field, including continuation lines.
+		n := 0
+		for {
+			i := bytes.Index(hdr[n:], []byte("\r\n"))
+			if i < 0 {
+				n = len(hdr)
+				break
+			}
+			n += i + 2
+			if n >= len(hdr) || hdr[n] != ' ' && hdr[n] != '\t' {
+				break
+			}
+		}
+		fields = append(fields, hdr[:n])
+		hdr = hdr[n:]
+	}
+
+	var r []byte
+	replace := map[string]string{"from": from, "subject": subject}
+	for _, f := range fields {
+		k, _, _ := bytes.Cut(f, []byte(":"))
+		key := strings.ToLower(strings.TrimSpace(string(k)))
+		if key == "dkim-signature" {
+			continue
+		}
+		if v, ok := replace[key]; ok && v != "" {
+			if key == "from" {
+				r = append(r, "From: "+v+"\r\n"...)
+			} else {
+				r = append(r, "Subject: "+v+"\r\n"...)
+			}
+			replace[key] = ""
+			continue
+		}
+		r = append(r, f...)
+	}
+	if replace["from"] != "" {
+		r = append(r, "From: "+from+"\r\n"...)
+	}
+	if replace["subject"] != "" {
+		r = append(r, "Subject: "+subject+"\r\n"...)
+	}
+	return r
+}
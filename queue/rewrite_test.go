@@ -0,0 +1,91 @@
+package queue
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestRewrite(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	path := smtp.Path{Localpart: "mjl", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "mox.example"}}}
+	mf := prepareFile(t)
+	defer os.Remove(mf.Name())
+	defer mf.Close()
+
+	prefix := []byte("DKIM-Signature: v=1; d=mox.example; s=test;\r\n\tb=abcd\r\n")
+	qm := MakeMsg(path, path, false, false, int64(len(prefix)+len(testmsg)), "<test@localhost>", prefix, nil, time.Now(), "test")
+	qm.Hold = true
+	err := Add(ctxbg, pkglog, "mjl", mf, qm)
+	tcheck(t, err, "add message to queue for delivery")
+	msgs, err := List(ctxbg, Filter{}, Sort{})
+	tcheck(t, err, "list queue")
+	tcompare(t, len(msgs), 1)
+	id := msgs[0].ID
+
+	ptr := func(s string) *string { return &s }
+
+	// Unknown message.
+	_, err = Rewrite(ctxbg, pkglog, id+1, MsgRewrite{Transport: ptr("")})
+	tcompare(t, err, bstore.ErrAbsent)
+
+	// Invalid changes.
+	_, err = Rewrite(ctxbg, pkglog, id, MsgRewrite{})
+	tcompare(t, errors.Is(err, ErrRewrite), true)
+	_, err = Rewrite(ctxbg, pkglog, id, MsgRewrite{Recipient: ptr("bogus")})
+	tcompare(t, errors.Is(err, ErrRewrite), true)
+	_, err = Rewrite(ctxbg, pkglog, id, MsgRewrite{Transport: ptr("bogus")})
+	tcompare(t, errors.Is(err, ErrRewrite), true)
+	_, err = Rewrite(ctxbg, pkglog, id, MsgRewrite{Subject: ptr("a\r\nBcc: x@example.org")})
+	tcompare(t, errors.Is(err, ErrRewrite), true)
+
+	// Envelope changes.
+	m, err := Rewrite(ctxbg, pkglog, id, MsgRewrite{Recipient: ptr("other@remote.example"), Transport: ptr("submit")})
+	tcheck(t, err, "rewrite envelope")
+	tcompare(t, m.Recipient().String(), "other@remote.example")
+	tcompare(t, m.RecipientDomainStr, "remote.example")
+	tcompare(t, m.Transport, "submit")
+	tcompare(t, len(m.Changes), 2)
+	tcompare(t, m.Hold, true)
+	tcompare(t, string(m.MsgPrefix), string(prefix))
+
+	// Header changes, DKIM-Signature is removed.
+	m, err = Rewrite(ctxbg, pkglog, id, MsgRewrite{From: ptr("Other <other@mox.example>"), Subject: ptr("fixed ☺")})
+	tcheck(t, err, "rewrite headers")
+	tcompare(t, len(m.Changes), 4)
+	tcompare(t, m.Subject, "fixed ☺")
+	tcompare(t, len(m.MsgPrefix), 0) // No DKIM configured for domain.
+
+	r, err := OpenMessage(ctxbg, id)
+	tcheck(t, err, "open message")
+	buf, err := io.ReadAll(r)
+	tcheck(t, err, "read message")
+	err = r.Close()
+	tcheck(t, err, "close message")
+	exp := strings.ReplaceAll(`From: "Other" <other@mox.example>
+To: <mjl@mox.example>
+Subject: =?utf-8?q?fixed_=E2=98=BA?=
+
+test email
+`, "\n", "\r\n")
+	tcompare(t, string(buf), exp)
+	tcompare(t, m.Size, int64(len(exp)))
+
+	// Audit trail is kept with retired message.
+	msgs, err = List(ctxbg, Filter{}, Sort{})
+	tcheck(t, err, "list queue")
+	tcompare(t, len(msgs[0].Changes), 4)
+	mr := msgs[0].Retired(false, time.Now(), time.Now())
+	tcompare(t, len(mr.Changes), 4)
+	tcompare(t, mr.Changes[0].Description, "recipient changed from mjl@mox.example to other@remote.example")
+}
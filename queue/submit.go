@@ -27,7 +27,7 @@ import (
 	"github.com/mjl-/mox/webhook"
 )
 
-// todo: reuse connection? do fewer concurrently (other than with direct delivery).
+// todo: do fewer concurrently (other than with direct delivery).
 
 // deliver via another SMTP server, e.g. relaying to a smart host, possibly
 // with authentication (submission).
@@ -97,102 +97,132 @@ func deliverSubmit(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 		return
 	}
 
-	dialctx, dialcancel := context.WithTimeout(ctx, 30*time.Second)
-	defer dialcancel()
 	if msgs[0].DialedIPs == nil {
 		msgs[0].DialedIPs = map[string][]net.IP{}
 		m0 = msgs[0]
 	}
-	_, _, _, ips, _, err := smtpclient.GatherIPs(dialctx, qlog.Logger, resolver, "ip", dns.IPDomain{Domain: transport.DNSHost}, m0.DialedIPs)
-	var conn net.Conn
-	if err == nil {
-		conn, _, err = smtpclient.Dial(dialctx, qlog.Logger, dialer, dns.IPDomain{Domain: transport.DNSHost}, ips, port, m0.DialedIPs, mox.Conf.Static.SpecifiedSMTPListenIPs)
-	}
 	addr := net.JoinHostPort(transport.Host, fmt.Sprintf("%d", port))
-	var result string
-	switch {
-	case err == nil:
-		result = "ok"
-	case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
-		result = "timeout"
-	case errors.Is(err, context.Canceled):
-		result = "canceled"
-	default:
-		result = "error"
-	}
-	metricConnection.WithLabelValues(result).Inc()
-	if err != nil {
-		if conn != nil {
-			err := conn.Close()
-			qlog.Check(err, "closing connection")
+
+	var transcript bytes.Buffer
+	var client *smtpclient.Client
+	var remoteIP net.IP
+	markSession := func() {
+		session := sessionResult(client, fmt.Sprintf("%s (%s)", transport.Host, remoteIP), transcript.String(), tlsPKIX, false)
+		for _, m := range msgs {
+			m.markSession(session)
 		}
-		qlog.Errorx("dialing for submission", err, slog.String("remote", addr))
-		submiterr = fmt.Errorf("transport %s: dialing %s for submission: %w", transportName, addr, err)
-		failMsgsDB(qlog, msgs, m0.DialedIPs, backoff, dsn.NameIP{}, submiterr)
-		return
 	}
-	dialcancel()
 
-	var auth func(mechanisms []string, cs *tls.ConnectionState) (sasl.Client, error)
-	if transport.Auth != nil {
-		a := transport.Auth
-		auth = func(mechanisms []string, cs *tls.ConnectionState) (sasl.Client, error) {
-			var supportsscramsha1plus, supportsscramsha256plus bool
-			for _, mech := range a.EffectiveMechanisms {
-				if !slices.Contains(mechanisms, mech) {
-					switch mech {
-					case "SCRAM-SHA-1-PLUS":
-						supportsscramsha1plus = cs != nil
-					case "SCRAM-SHA-256-PLUS":
-						supportsscramsha256plus = cs != nil
+	// Reuse an idle (authenticated) session with the submission server if possible.
+	poolKey := connKey{
+		transportName: transportName,
+		transport:     transport,
+		ehloHostname:  mox.Conf.Static.HostnameDomain.ASCII,
+		host:          transport.DNSHost.ASCII,
+		port:          port,
+		tlsMode:       tlsMode,
+		tlsPKIX:       tlsPKIX,
+	}
+	pc := connPoolGet(qlog, poolKey)
+	if pc != nil {
+		client, remoteIP = pc.client, pc.remoteIP
+		client.SetTranscript(&transcript)
+	} else {
+		dialctx, dialcancel := context.WithTimeout(ctx, 30*time.Second)
+		defer dialcancel()
+		_, _, _, ips, _, err := smtpclient.GatherIPs(dialctx, qlog.Logger, resolver, "ip", dns.IPDomain{Domain: transport.DNSHost}, m0.DialedIPs)
+		var conn net.Conn
+		if err == nil {
+			conn, remoteIP, err = smtpclient.Dial(dialctx, qlog.Logger, dialer, dns.IPDomain{Domain: transport.DNSHost}, ips, port, m0.DialedIPs, mox.Conf.Static.SpecifiedSMTPListenIPs, smtpclient.DialPolicy{HappyEyeballsDelay: smtpclient.DefaultHappyEyeballsDelay})
+		}
+		var result string
+		switch {
+		case err == nil:
+			result = "ok"
+		case errors.Is(err, os.ErrDeadlineExceeded), errors.Is(err, context.DeadlineExceeded):
+			result = "timeout"
+		case errors.Is(err, context.Canceled):
+			result = "canceled"
+		default:
+			result = "error"
+		}
+		metricConnection.WithLabelValues(result).Inc()
+		if err != nil {
+			if conn != nil {
+				err := conn.Close()
+				qlog.Check(err, "closing connection")
+			}
+			qlog.Errorx("dialing for submission", err, slog.String("remote", addr))
+			submiterr = fmt.Errorf("transport %s: dialing %s for submission: %w", transportName, addr, err)
+			failMsgsDB(qlog, msgs, m0.DialedIPs, backoff, dsn.NameIP{}, submiterr)
+			return
+		}
+		dialcancel()
+		mox.Connections.Register(conn, "smtpclient", "queue")
+		pc = &pooledConn{conn: conn, remoteIP: remoteIP}
+
+		var auth func(mechanisms []string, cs *tls.ConnectionState) (sasl.Client, error)
+		if transport.Auth != nil {
+			a := transport.Auth
+			auth = func(mechanisms []string, cs *tls.ConnectionState) (sasl.Client, error) {
+				var supportsscramsha1plus, supportsscramsha256plus bool
+				for _, mech := range a.EffectiveMechanisms {
+					if !slices.Contains(mechanisms, mech) {
+						switch mech {
+						case "SCRAM-SHA-1-PLUS":
+							supportsscramsha1plus = cs != nil
+						case "SCRAM-SHA-256-PLUS":
+							supportsscramsha256plus = cs != nil
+						}
+						continue
 					}
-					continue
-				}
-				if mech == "SCRAM-SHA-256-PLUS" && cs != nil {
-					return sasl.NewClientSCRAMSHA256PLUS(a.Username, a.Password, *cs), nil
-				} else if mech == "SCRAM-SHA-256" {
-					return sasl.NewClientSCRAMSHA256(a.Username, a.Password, supportsscramsha256plus), nil
-				} else if mech == "SCRAM-SHA-1-PLUS" && cs != nil {
-					return sasl.NewClientSCRAMSHA1PLUS(a.Username, a.Password, *cs), nil
-				} else if mech == "SCRAM-SHA-1" {
-					return sasl.NewClientSCRAMSHA1(a.Username, a.Password, supportsscramsha1plus), nil
-				} else if mech == "CRAM-MD5" {
-					return sasl.NewClientCRAMMD5(a.Username, a.Password), nil
-				} else if mech == "PLAIN" {
-					return sasl.NewClientPlain(a.Username, a.Password), nil
+					if mech == "SCRAM-SHA-256-PLUS" && cs != nil {
+						return sasl.NewClientSCRAMSHA256PLUS(a.Username, a.Password, *cs), nil
+					} else if mech == "SCRAM-SHA-256" {
+						return sasl.NewClientSCRAMSHA256(a.Username, a.Password, supportsscramsha256plus), nil
+					} else if mech == "SCRAM-SHA-1-PLUS" && cs != nil {
+						return sasl.NewClientSCRAMSHA1PLUS(a.Username, a.Password, *cs), nil
+					} else if mech == "SCRAM-SHA-1" {
+						return sasl.NewClientSCRAMSHA1(a.Username, a.Password, supportsscramsha1plus), nil
+					} else if mech == "CRAM-MD5" {
+						return sasl.NewClientCRAMMD5(a.Username, a.Password), nil
+					} else if mech == "PLAIN" {
+						return sasl.NewClientPlain(a.Username, a.Password), nil
+					}
+					return nil, fmt.Errorf("internal error: unrecognized authentication mechanism %q for transport %s", mech, transportName)
 				}
-				return nil, fmt.Errorf("internal error: unrecognized authentication mechanism %q for transport %s", mech, transportName)
-			}
 
-			// No mutually supported algorithm.
-			return nil, nil
+				// No mutually supported algorithm.
+				return nil, nil
+			}
 		}
-	}
-	clientctx, clientcancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer clientcancel()
-	opts := smtpclient.Opts{
-		Auth:    auth,
-		RootCAs: mox.Conf.Static.TLS.CertPool,
-	}
-	client, err := smtpclient.New(clientctx, qlog.Logger, conn, tlsMode, tlsPKIX, mox.Conf.Static.HostnameDomain, transport.DNSHost, opts)
-	if err != nil {
-		smtperr, ok := err.(smtpclient.Error)
-		var remoteMTA dsn.NameIP
-		submiterr = fmt.Errorf("transport %s: establishing smtp session with %s for submission: %w", transportName, addr, err)
-		if ok {
-			remoteMTA.Name = transport.Host
-			smtperr.Err = submiterr
-			submiterr = smtperr
+		clientctx, clientcancel := context.WithTimeout(context.Background(), 60*time.Second)
+		defer clientcancel()
+		opts := smtpclient.Opts{
+			Auth:       auth,
+			RootCAs:    mox.Conf.Static.TLS.CertPool,
+			Transcript: &transcript,
 		}
-		qlog.Errorx("establishing smtp session for submission", submiterr, slog.String("remote", addr))
-		failMsgsDB(qlog, msgs, m0.DialedIPs, backoff, remoteMTA, submiterr)
-		return
+		client, err = smtpclient.New(clientctx, qlog.Logger, conn, tlsMode, tlsPKIX, mox.Conf.Static.HostnameDomain, transport.DNSHost, opts)
+		if err != nil {
+			pc.close(qlog)
+			markSession()
+			smtperr, ok := err.(smtpclient.Error)
+			var remoteMTA dsn.NameIP
+			submiterr = fmt.Errorf("transport %s: establishing smtp session with %s for submission: %w", transportName, addr, err)
+			if ok {
+				remoteMTA.Name = transport.Host
+				smtperr.Err = submiterr
+				submiterr = smtperr
+			}
+			qlog.Errorx("establishing smtp session for submission", submiterr, slog.String("remote", addr))
+			failMsgsDB(qlog, msgs, m0.DialedIPs, backoff, remoteMTA, submiterr)
+			return
+		}
+		pc.client = client
+		clientcancel()
 	}
-	defer func() {
-		err := client.Close()
-		qlog.Check(err, "closing smtp client after delivery")
-	}()
-	clientcancel()
+	defer connPoolPut(qlog, poolKey, pc)
 
 	var msgr io.ReadCloser
 	var size int64
@@ -228,6 +258,7 @@ func deliverSubmit(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 	for i, m := range msgs {
 		rcpts[i] = m.Recipient().String()
 	}
+	client.SetMTPriority(m0.Priority)
 	rcptErrs, submiterr := client.DeliverMultiple(deliverctx, m0.Sender().String(), rcpts, size, msgr, req8bit, reqsmtputf8, requireTLS)
 	if submiterr != nil {
 		qlog.Infox("smtp transaction for delivery failed", submiterr)
@@ -239,6 +270,7 @@ func deliverSubmit(qlog mlog.Log, resolver dns.Resolver, dialer smtpclient.Diale
 	qlog.Check(cerr, "closing message after delivery attempt")
 	msgr = nil
 
+	markSession()
 	failed, delivered = processDeliveries(qlog, m0, msgs, addr, transport.Host, backoff, rcptErrs, submiterr)
 }
 
@@ -273,7 +305,7 @@ func processDeliveries(qlog mlog.Log, m0 *Msg, msgs []*Msg, remoteAddr string, r
 			failed++
 		} else {
 			resp := rcptErrs[i]
-			m.markResult(resp.Code, resp.Secode, "", true)
+			m.markResult(resp.Code, resp.Secode, responseLines(resp.Line, resp.MoreLines), "", true)
 			delMsgs = append(delMsgs, *m)
 			qmlog.Info("delivered from queue with transport")
 			delivered++
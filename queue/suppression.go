@@ -12,6 +12,7 @@ import (
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/webapi"
+	"github.com/mjl-/mox/webhook"
 )
 
 // todo: we should be processing spam complaints and add addresses to the list.
@@ -91,6 +92,7 @@ type suppressionCheck struct {
 	Recipient smtp.Path
 	Code      int
 	Secode    string
+	Lines     []string // SMTP response or DSN diagnostic code, for classification.
 	Source    string
 }
 
@@ -112,10 +114,11 @@ func suppressionProcess(log mlog.Log, tx *bstore.Tx, scl ...suppressionCheck) (s
 			Account:         sc.Account,
 			BaseAddress:     baseAddr,
 			OriginalAddress: origAddr,
+			BounceClass:     bounceClassify(sc.Code, sc.Secode, sc.Lines),
 		}
 
-		if isImmedateBlock(sc.Code, sc.Secode) {
-			sup.Reason = fmt.Sprintf("delivery failure from %s with smtp code %d, enhanced code %q", sc.Source, sc.Code, sc.Secode)
+		if isImmedateBlock(sc.Code, sc.Secode) || sup.BounceClass == webhook.BounceHard {
+			sup.Reason = fmt.Sprintf("delivery failure from %s with smtp code %d, enhanced code %q, %s bounce", sc.Source, sc.Code, sc.Secode, sup.BounceClass)
 		} else {
 			// If two most recent deliveries failed (excluding this one, so three most recent
 			// messages including this one), we'll add the address to the list.
@@ -132,7 +135,7 @@ func suppressionProcess(log mlog.Log, tx *bstore.Tx, scl ...suppressionCheck) (s
 			if len(l) < 2 || l[0].Success || l[1].Success {
 				continue
 			}
-			sup.Reason = fmt.Sprintf("delivery failure from %s and three consecutive failures", sc.Source)
+			sup.Reason = fmt.Sprintf("delivery failure from %s and three consecutive failures, %s bounce", sc.Source, sup.BounceClass)
 		}
 		if err := tx.Insert(&sup); err != nil {
 			return nil, fmt.Errorf("inserting suppression: %v", err)
@@ -0,0 +1,80 @@
+package queue
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+var metricTLSPolicyViolation = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "mox_queue_tls_policy_violation_total",
+		Help: "Delivery attempts to a host failed because the TLS policy configured for the recipient domain was not satisfied, per reason: tls (no TLS, or failed verification), certificate (certificate not matching pinned fingerprints).",
+	},
+	[]string{"reason"},
+)
+
+// errTLSPolicyCertificate is returned, wrapped in smtpclient.ErrTLS, if the
+// certificate of a remote host does not match the fingerprints of the TLS policy.
+var errTLSPolicyCertificate = errors.New("certificate does not match fingerprints in tls policy")
+
+// findTLSPolicy returns the first configured TLS policy matching the recipient
+// domain of the message. If none match, a zero policy is returned, causing default
+// behaviour.
+func findTLSPolicy(m Msg) config.TLSPolicy {
+	if m.RecipientDomain.IsIP() {
+		return config.TLSPolicy{}
+	}
+	for _, tp := range mox.Conf.TLSPolicies() {
+		if routeMatchDomain(tp.ToDomainASCII, m.RecipientDomain.Domain) {
+			return tp
+		}
+	}
+	return config.TLSPolicy{}
+}
+
+// tlsPolicyRequired returns whether the TLS policy requires STARTTLS, i.e. no
+// fallback to plain text.
+func tlsPolicyRequired(tp config.TLSPolicy) bool {
+	return tp.Mode == "verify" || tp.Mode == "required" || len(tp.CertificateSHA256Parsed) > 0
+}
+
+// msgTLSRequiredNo returns whether TLS requirements from MTA-STS and DANE are
+// ignored for delivery of the message, due to a "TLS-Required: No" message header
+// or a TLS policy with mode optional. A TLS policy requiring TLS takes precedence
+// over the message header, a message sent with REQUIRETLS over the TLS policy.
+func msgTLSRequiredNo(m Msg, tp config.TLSPolicy) bool {
+	if tlsPolicyRequired(tp) {
+		return false
+	}
+	if m.RequireTLS != nil {
+		return !*m.RequireTLS
+	}
+	return tp.Mode == "optional"
+}
+
+// tlsPolicyCheckCertificate checks the leaf certificate of the TLS connection
+// against the fingerprints of the TLS policy, if any.
+func tlsPolicyCheckCertificate(cs *tls.ConnectionState, tp config.TLSPolicy) error {
+	if len(tp.CertificateSHA256Parsed) == 0 {
+		return nil
+	}
+	if cs == nil || len(cs.PeerCertificates) == 0 {
+		return fmt.Errorf("%w: %w: no tls connection", smtpclient.ErrTLS, errTLSPolicyCertificate)
+	}
+	sum := sha256.Sum256(cs.PeerCertificates[0].Raw)
+	if !slices.ContainsFunc(tp.CertificateSHA256Parsed, func(fp []byte) bool { return bytes.Equal(fp, sum[:]) }) {
+		return fmt.Errorf("%w: %w: sha-256 fingerprint %x", smtpclient.ErrTLS, errTLSPolicyCertificate, sum[:])
+	}
+	return nil
+}
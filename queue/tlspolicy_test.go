@@ -0,0 +1,78 @@
+package queue
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtpclient"
+)
+
+func TestTLSPolicy(t *testing.T) {
+	_, cleanup := setup(t)
+	defer cleanup()
+
+	cert := fakeCert(t, "mail.partner.example", false)
+	fp := sha256.Sum256(cert.Leaf.Raw)
+
+	mox.Conf.Dynamic.TLSPolicies = []config.TLSPolicy{
+		{
+			ToDomainASCII:           []string{".partner.example"},
+			Mode:                    "verify",
+			CertificateSHA256Parsed: [][]byte{fp[:]},
+		},
+		{
+			ToDomainASCII: []string{"legacy.example"},
+			Mode:          "optional",
+		},
+	}
+	defer func() {
+		mox.Conf.Dynamic.TLSPolicies = nil
+	}()
+
+	yes, no := true, false
+	msg := func(domain string, requireTLS *bool) Msg {
+		return Msg{RecipientDomain: dns.IPDomain{Domain: dns.Domain{ASCII: domain}}, RequireTLS: requireTLS}
+	}
+
+	// No policy, default behaviour.
+	m := msg("other.example", nil)
+	tp := findTLSPolicy(m)
+	tcompare(t, tlsPolicyRequired(tp), false)
+	tcompare(t, msgTLSRequiredNo(m, tp), false)
+	m.RequireTLS = &no
+	tcompare(t, msgTLSRequiredNo(m, tp), true)
+
+	// Policy requiring verified TLS, including subdomains, overrides TLS-Required: No.
+	m = msg("mx.partner.example", &no)
+	tp = findTLSPolicy(m)
+	tcompare(t, tp.Mode, "verify")
+	tcompare(t, tlsPolicyRequired(tp), true)
+	tcompare(t, msgTLSRequiredNo(m, tp), false)
+
+	// Policy allowing plain text fallback, but not for REQUIRETLS.
+	m = msg("legacy.example", nil)
+	tp = findTLSPolicy(m)
+	tcompare(t, tlsPolicyRequired(tp), false)
+	tcompare(t, msgTLSRequiredNo(m, tp), true)
+	m.RequireTLS = &yes
+	tcompare(t, msgTLSRequiredNo(m, tp), false)
+
+	// Pinned certificates.
+	tp = findTLSPolicy(msg("partner.example", nil))
+	err := tlsPolicyCheckCertificate(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert.Leaf}}, tp)
+	tcheck(t, err, "check pinned certificate")
+	other := fakeCert(t, "other.partner.example", false)
+	err = tlsPolicyCheckCertificate(&tls.ConnectionState{PeerCertificates: []*x509.Certificate{other.Leaf}}, tp)
+	tcompare(t, errors.Is(err, errTLSPolicyCertificate), true)
+	tcompare(t, errors.Is(err, smtpclient.ErrTLS), true)
+	err = tlsPolicyCheckCertificate(nil, tp)
+	tcompare(t, errors.Is(err, errTLSPolicyCertificate), true)
+	err = tlsPolicyCheckCertificate(nil, config.TLSPolicy{})
+	tcheck(t, err, "check without pinned certificates")
+}
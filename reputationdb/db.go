@@ -0,0 +1,166 @@
+// Package reputationdb keeps reputation of sending domains and IPs of incoming
+// email, shared across all accounts.
+//
+// For each organizational domain of a message From address, and for each remote
+// IP (IPv6 addresses per /64), historic authentication results, accepted and
+// rejected deliveries, junk/notjunk actions of users and messages sent to
+// spamtrap addresses are aggregated. The reputation is consulted during SMTP
+// delivery when an account has no conclusive reputation of its own. Admins can
+// override the reputation of a sender to always allow or always quarantine its
+// messages.
+package reputationdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+)
+
+var (
+	DBTypes = []any{Sender{}}
+	DB      *bstore.DB
+)
+
+// SenderType indicates whether a Sender is a domain or IP.
+type SenderType string
+
+const (
+	SenderDomain SenderType = "domain" // Organizational domain of message From address, unicode.
+	SenderIP     SenderType = "ip"     // IPv4 address, or IPv6 /64 network.
+)
+
+// Override is an admin decision for a sender, taking precedence over the
+// reputation.
+type Override string
+
+const (
+	OverrideNone       Override = ""
+	OverrideAllow      Override = "allow"      // Always accept messages.
+	OverrideQuarantine Override = "quarantine" // Always deliver to the Junk mailbox.
+)
+
+// Sender is the aggregated reputation of a sending domain or IP.
+type Sender struct {
+	ID int64
+
+	Type  SenderType `bstore:"nonzero,unique Type+Value"`
+	Value string     `bstore:"nonzero"`
+
+	First time.Time `bstore:"default now"`
+	Last  time.Time `bstore:"default now,index"` // Last delivery attempt.
+
+	// Delivery attempts. A single delivery attempt can be for multiple recipients.
+	// For domains, only deliveries with a validated message From address are counted
+	// as accepted or rejected.
+	Accepted          int64 // Delivered to at least one recipient.
+	Rejected          int64 // Refused for at least one recipient, e.g. as junk or for policy.
+	UnknownRecipients int64 // Recipients refused because they do not exist, a bounce in response.
+
+	// Authentication results of delivery attempts.
+	SPFPass   int64
+	DKIMPass  int64 // At least one valid DKIM signature.
+	DMARCPass int64
+	DMARCFail int64
+
+	// Messages marked as junk or not junk by users, after delivery. For domains, only
+	// for messages with a validated message From address.
+	Junk    int64
+	Notjunk int64
+
+	// Messages sent to spamtrap addresses. For domains, only for messages with a
+	// validated From address.
+	Spamtraps int64
+
+	Override        Override
+	OverrideUpdated time.Time // Time of last change to Override.
+}
+
+// Init opens the database.
+func Init() error {
+	if DB != nil {
+		return fmt.Errorf("already initialized")
+	}
+
+	log := mlog.New("reputationdb", nil)
+	p := mox.DataDirPath("reputation.db")
+	os.MkdirAll(filepath.Dir(p), 0770)
+	opts := bstore.Options{Timeout: 5 * time.Second, Perm: 0660, RegisterLogger: moxvar.RegisterLogger(p, log.Logger)}
+	var err error
+	DB, err = bstore.Open(mox.Shutdown, p, &opts, DBTypes...)
+	return err
+}
+
+// Close closes the database.
+func Close() error {
+	if err := DB.Close(); err != nil {
+		return fmt.Errorf("closing db: %w", err)
+	}
+	DB = nil
+	return nil
+}
+
+// List returns senders, most recently seen first. If typ is non-empty, only
+// senders of that type are returned. If overrides is set, only senders with an
+// override are returned. At most limit senders are returned, if limit > 0.
+func List(ctx context.Context, typ SenderType, overrides bool, limit int) ([]Sender, error) {
+	q := bstore.QueryDB[Sender](ctx, DB)
+	if typ != "" {
+		q.FilterNonzero(Sender{Type: typ})
+	}
+	if overrides {
+		q.FilterNotEqual("Override", OverrideNone)
+	}
+	q.SortDesc("Last")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	return q.List()
+}
+
+// Get returns the sender, or bstore.ErrAbsent.
+func Get(ctx context.Context, typ SenderType, value string) (Sender, error) {
+	return bstore.QueryDB[Sender](ctx, DB).FilterNonzero(Sender{Type: typ, Value: value}).Get()
+}
+
+// SetOverride sets the override for a sender, adding a sender without history if
+// it does not yet exist.
+func SetOverride(ctx context.Context, typ SenderType, value string, override Override) error {
+	switch typ {
+	case SenderDomain, SenderIP:
+	default:
+		return fmt.Errorf("unknown sender type %q", typ)
+	}
+	switch override {
+	case OverrideNone, OverrideAllow, OverrideQuarantine:
+	default:
+		return fmt.Errorf("unknown override %q", override)
+	}
+	if value == "" {
+		return fmt.Errorf("missing sender")
+	}
+
+	return DB.Write(ctx, func(tx *bstore.Tx) error {
+		s, err := bstore.QueryTx[Sender](tx).FilterNonzero(Sender{Type: typ, Value: value}).Get()
+		if errors.Is(err, bstore.ErrAbsent) {
+			if override == OverrideNone {
+				return nil
+			}
+			s = Sender{Type: typ, Value: value, Override: override, OverrideUpdated: time.Now()}
+			return tx.Insert(&s)
+		} else if err != nil {
+			return err
+		}
+		s.Override = override
+		s.OverrideUpdated = time.Now()
+		return tx.Update(&s)
+	})
+}
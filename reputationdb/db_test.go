@@ -0,0 +1,134 @@
+package reputationdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheckf(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", fmt.Sprintf(format, args...), err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestReputation(t *testing.T) {
+	mox.Shutdown = ctxbg
+	mox.Conf.Static.DataDir = t.TempDir()
+	log := mlog.New("reputationdb", nil)
+
+	err := Init()
+	tcheckf(t, err, "init database")
+	defer Close()
+
+	evaluate := func(domain, ip string, expOverride Override, expJunk *bool) {
+		t.Helper()
+		ev, err := Evaluate(ctxbg, domain, ip)
+		tcheckf(t, err, "evaluate")
+		tcompare(t, ev.Override, expOverride)
+		if (ev.Junk == nil) != (expJunk == nil) || ev.Junk != nil && *ev.Junk != *expJunk {
+			t.Fatalf("got junk %v, expected %v (%s)", ev.Junk, expJunk, ev.Text)
+		}
+	}
+	yes, no := true, false
+
+	// No history.
+	evaluate("mox.example", "192.0.2.1", OverrideNone, nil)
+
+	// Deliveries. Only validated deliveries count for the domain.
+	err = AddDelivery(ctxbg, Delivery{Domain: "mox.example", DomainValidated: true, IP: "192.0.2.1", SPFPass: true, DMARCPass: true, Accepted: true})
+	tcheckf(t, err, "add delivery")
+	err = AddDelivery(ctxbg, Delivery{Domain: "mox.example", IP: "192.0.2.2", DMARCFail: true, Rejected: true})
+	tcheckf(t, err, "add delivery")
+	s, err := Get(ctxbg, SenderDomain, "mox.example")
+	tcheckf(t, err, "get domain")
+	tcompare(t, []int64{s.Accepted, s.Rejected, s.SPFPass, s.DMARCPass, s.DMARCFail}, []int64{1, 0, 1, 1, 1})
+	l, err := List(ctxbg, SenderIP, false, 0)
+	tcheckf(t, err, "list ips")
+	tcompare(t, len(l), 2)
+	evaluate("mox.example", "192.0.2.1", OverrideNone, nil)
+
+	// Junk marks by users.
+	m := store.Message{MsgFromValidated: true, MsgFromOrgDomain: "mox.example", RemoteIPMasked1: "192.0.2.1"}
+	for range 3 {
+		JunkMarked(ctxbg, log, m, true)
+	}
+	evaluate("mox.example", "", OverrideNone, &yes)
+	evaluate("", "192.0.2.1", OverrideNone, &yes)
+	for range 9 {
+		JunkMarked(ctxbg, log, m, false)
+	}
+	evaluate("mox.example", "192.0.2.1", OverrideNone, &no)
+
+	// Domain of forwarded messages is not counted.
+	m.IsForward = true
+	m.RemoteIPMasked1 = ""
+	JunkMarked(ctxbg, log, m, true)
+	s, err = Get(ctxbg, SenderDomain, "mox.example")
+	tcheckf(t, err, "get domain")
+	tcompare(t, s.Junk, 3)
+
+	// Attempts to deliver to unknown recipients only.
+	err = AddDelivery(ctxbg, Delivery{IP: "192.0.2.3", UnknownRecipients: 10})
+	tcheckf(t, err, "add delivery")
+	evaluate("", "192.0.2.3", OverrideNone, &yes)
+
+	// Messages to spamtraps.
+	for i := range 3 {
+		senders, err := SpamtrapHit(ctxbg, "spam.example", "192.0.2.4")
+		tcheckf(t, err, "spamtrap hit")
+		tcompare(t, len(senders), 2)
+		tcompare(t, senders[1].Spamtraps, int64(i+1))
+	}
+	evaluate("spam.example", "", OverrideNone, &yes)
+	evaluate("", "192.0.2.4", OverrideNone, &yes)
+	// Not junk marks by users disable the spamtrap rule, against trap poisoning.
+	m = store.Message{MsgFromValidated: true, MsgFromOrgDomain: "spam.example"}
+	JunkMarked(ctxbg, log, m, false)
+	evaluate("spam.example", "", OverrideNone, nil)
+	// The junk marks of users take precedence.
+	for range 3 {
+		_, err = SpamtrapHit(ctxbg, "mox.example", "")
+		tcheckf(t, err, "spamtrap hit")
+	}
+	evaluate("mox.example", "", OverrideNone, &no)
+
+	// Overrides take precedence, the domain over the IP.
+	err = SetOverride(ctxbg, SenderIP, "192.0.2.1", OverrideQuarantine)
+	tcheckf(t, err, "set override")
+	evaluate("mox.example", "192.0.2.1", OverrideQuarantine, nil)
+	err = SetOverride(ctxbg, SenderDomain, "other.example", OverrideAllow)
+	tcheckf(t, err, "set override for new sender")
+	err = SetOverride(ctxbg, SenderDomain, "mox.example", OverrideAllow)
+	tcheckf(t, err, "set override")
+	evaluate("mox.example", "192.0.2.1", OverrideAllow, nil)
+	l, err = List(ctxbg, "", true, 0)
+	tcheckf(t, err, "list overrides")
+	tcompare(t, len(l), 3)
+	err = SetOverride(ctxbg, SenderDomain, "mox.example", OverrideNone)
+	tcheckf(t, err, "clear override")
+	evaluate("mox.example", "", OverrideNone, &no)
+
+	err = SetOverride(ctxbg, "bogus", "mox.example", OverrideAllow)
+	if err == nil {
+		t.Fatalf("set override with unknown type succeeded")
+	}
+	err = SetOverride(ctxbg, SenderDomain, "mox.example", "bogus")
+	if err == nil {
+		t.Fatalf("set override with unknown override succeeded")
+	}
+}
@@ -0,0 +1,233 @@
+package reputationdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+// Delivery is an incoming delivery attempt over SMTP, for updating the reputation
+// of its sending domain and IP.
+type Delivery struct {
+	Domain          string // Organizational domain of message From address, unicode. Can be empty.
+	DomainValidated bool   // Whether the message From address was validated, e.g. with DMARC.
+	IP              string // Remote IP, IPv6 masked to /64, like store.Message.RemoteIPMasked1.
+
+	SPFPass   bool
+	DKIMPass  bool
+	DMARCPass bool
+	DMARCFail bool
+
+	Accepted          bool // Delivered to at least one recipient.
+	Rejected          bool // Refused for at least one recipient.
+	UnknownRecipients int  // Number of recipients that do not exist.
+}
+
+// upsert fetches the sender for type and value, calls fn to update it, and stores
+// it, inserting it if it did not yet exist.
+func upsert(tx *bstore.Tx, typ SenderType, value string, fn func(s *Sender)) error {
+	s, err := bstore.QueryTx[Sender](tx).FilterNonzero(Sender{Type: typ, Value: value}).Get()
+	if errors.Is(err, bstore.ErrAbsent) {
+		s = Sender{Type: typ, Value: value}
+		fn(&s)
+		return tx.Insert(&s)
+	} else if err != nil {
+		return err
+	}
+	fn(&s)
+	return tx.Update(&s)
+}
+
+// AddDelivery updates the reputation of the sending domain and IP with a delivery
+// attempt.
+func AddDelivery(ctx context.Context, d Delivery) error {
+	now := time.Now()
+	return DB.Write(ctx, func(tx *bstore.Tx) error {
+		update := func(s *Sender, validated bool) {
+			s.Last = now
+			if validated {
+				if d.Accepted {
+					s.Accepted++
+				}
+				if d.Rejected {
+					s.Rejected++
+				}
+				s.UnknownRecipients += int64(d.UnknownRecipients)
+			}
+			if d.SPFPass {
+				s.SPFPass++
+			}
+			if d.DKIMPass {
+				s.DKIMPass++
+			}
+			if d.DMARCPass {
+				s.DMARCPass++
+			}
+			if d.DMARCFail {
+				s.DMARCFail++
+			}
+		}
+
+		// Anyone can put any domain in a message From address, only validated deliveries
+		// count for the domain reputation. Authentication failures are still useful
+		// to see.
+		if d.Domain != "" {
+			if err := upsert(tx, SenderDomain, d.Domain, func(s *Sender) { update(s, d.DomainValidated) }); err != nil {
+				return fmt.Errorf("updating domain reputation: %v", err)
+			}
+		}
+		if d.IP != "" {
+			if err := upsert(tx, SenderIP, d.IP, func(s *Sender) { update(s, true) }); err != nil {
+				return fmt.Errorf("updating ip reputation: %v", err)
+			}
+		}
+		return nil
+	})
+}
+
+// JunkMarked updates the reputation of the sending domain and IP of a message
+// delivered over SMTP, after a user marked it as junk or not junk. Errors are
+// logged. Can be set as store.SenderJunkMarked.
+func JunkMarked(ctx context.Context, log mlog.Log, m store.Message, junk bool) {
+	err := DB.Write(ctx, func(tx *bstore.Tx) error {
+		update := func(s *Sender) {
+			if junk {
+				s.Junk++
+			} else {
+				s.Notjunk++
+			}
+		}
+		// Domain is only set for validated addresses, also not for forwarded messages.
+		if m.MsgFromValidated && m.MsgFromOrgDomain != "" && !m.IsForward {
+			if err := upsert(tx, SenderDomain, m.MsgFromOrgDomain, update); err != nil {
+				return fmt.Errorf("updating domain reputation: %v", err)
+			}
+		}
+		// Forwarded messages have the masked IPs cleared, the forwarder is not responsible.
+		if m.RemoteIPMasked1 != "" {
+			if err := upsert(tx, SenderIP, m.RemoteIPMasked1, update); err != nil {
+				return fmt.Errorf("updating ip reputation: %v", err)
+			}
+		}
+		return nil
+	})
+	log.Check(err, "updating sender reputation for junk status of message", slog.Int64("msgid", m.ID), slog.Bool("junk", junk))
+}
+
+// SpamtrapHit updates the reputation of the sending domain and IP of a message
+// sent to a spamtrap address. Domain must only be set if the message From address
+// was validated. The updated senders are returned.
+func SpamtrapHit(ctx context.Context, domain, ip string) (senders []Sender, rerr error) {
+	now := time.Now()
+	rerr = DB.Write(ctx, func(tx *bstore.Tx) error {
+		update := func(s *Sender) {
+			s.Last = now
+			s.Spamtraps++
+			senders = append(senders, *s)
+		}
+		if domain != "" {
+			if err := upsert(tx, SenderDomain, domain, update); err != nil {
+				return fmt.Errorf("updating domain reputation: %v", err)
+			}
+		}
+		if ip != "" {
+			if err := upsert(tx, SenderIP, ip, update); err != nil {
+				return fmt.Errorf("updating ip reputation: %v", err)
+			}
+		}
+		return nil
+	})
+	return
+}
+
+// Evaluation is the sender reputation for a delivery attempt.
+type Evaluation struct {
+	// Override for the sender, the domain override takes precedence over the IP
+	// override.
+	Override Override
+	// If not nil, whether the reputation indicates junk. Nil if the reputation is
+	// inconclusive.
+	Junk *bool
+	// Human-readable explanation, e.g. for the X-Mox-Reason header.
+	Text string
+}
+
+// Minimum number of junk/notjunk marks before the ratio is used, and the ratios
+// for a conclusion.
+const (
+	minMarked      = 3
+	junkRatio      = 0.75
+	notjunkRatio   = 0.25
+	minUnknownRcpt = 10
+	minSpamtraps   = 3
+)
+
+// Evaluate returns the reputation of a delivery attempt. Domain must only be set
+// if the message From address was validated.
+func Evaluate(ctx context.Context, domain, ip string) (Evaluation, error) {
+	var ev Evaluation
+	var senders []Sender
+	for _, k := range []struct {
+		typ   SenderType
+		value string
+	}{{SenderDomain, domain}, {SenderIP, ip}} {
+		if k.value == "" {
+			continue
+		}
+		s, err := Get(ctx, k.typ, k.value)
+		if errors.Is(err, bstore.ErrAbsent) {
+			continue
+		} else if err != nil {
+			return ev, fmt.Errorf("get sender reputation: %v", err)
+		}
+		senders = append(senders, s)
+	}
+
+	for _, s := range senders {
+		if s.Override != OverrideNone {
+			ev.Override = s.Override
+			ev.Text = fmt.Sprintf("sender %s %s has override %s", s.Type, s.Value, s.Override)
+			return ev, nil
+		}
+	}
+
+	conclude := func(junk bool, format string, args ...any) (Evaluation, error) {
+		ev.Junk = &junk
+		ev.Text = fmt.Sprintf(format, args...)
+		return ev, nil
+	}
+	for _, s := range senders {
+		if n := s.Junk + s.Notjunk; n >= minMarked {
+			ratio := float64(s.Junk) / float64(n)
+			if ratio >= junkRatio {
+				return conclude(true, "sender %s %s marked as junk %d of %d times", s.Type, s.Value, s.Junk, n)
+			} else if ratio <= notjunkRatio {
+				return conclude(false, "sender %s %s marked as not junk %d of %d times", s.Type, s.Value, s.Notjunk, n)
+			}
+		}
+	}
+	for _, s := range senders {
+		// No one has a legitimate reason to send to spamtrap addresses. Messages marked as
+		// not junk by users may indicate the spamtraps are being poisoned, e.g. with
+		// messages of a legitimate sender, so they disable this rule.
+		if s.Spamtraps >= minSpamtraps && s.Notjunk == 0 {
+			return conclude(true, "sender %s %s sent %d messages to spamtrap addresses", s.Type, s.Value, s.Spamtraps)
+		}
+	}
+	for _, s := range senders {
+		// Many attempts to deliver to non-existent addresses, and nothing delivered, looks
+		// like an address harvesting attempt.
+		if s.Type == SenderIP && s.Accepted == 0 && s.UnknownRecipients >= minUnknownRcpt {
+			return conclude(true, "sender %s %s only attempted deliveries to %d unknown recipients", s.Type, s.Value, s.UnknownRecipients)
+		}
+	}
+	ev.Text = "no conclusive server-wide sender reputation"
+	return ev, nil
+}
@@ -5,18 +5,24 @@ import (
 	"os"
 	"time"
 
+	"github.com/mjl-/mox/admin"
 	"github.com/mjl-/mox/dmarcdb"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/http"
 	"github.com/mjl-/mox/imapserver"
+	"github.com/mjl-/mox/ldap"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/mtastsdb"
+	"github.com/mjl-/mox/postmasterdb"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/smtpserver"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/tlsrptdb"
 	"github.com/mjl-/mox/tlsrptsend"
+	"github.com/mjl-/mox/webops"
 )
 
 func shutdown(log mlog.Log) {
@@ -82,6 +88,18 @@ func start(mtastsdbRefresher, sendDMARCReports, sendTLSReports, skipForkExec boo
 		return fmt.Errorf("dmarcdb init: %s", err)
 	}
 
+	if err := postmasterdb.Init(); err != nil {
+		return fmt.Errorf("postmasterdb init: %s", err)
+	}
+
+	if err := reputationdb.Init(); err != nil {
+		return fmt.Errorf("reputationdb init: %s", err)
+	}
+
+	if err := mlist.Init(); err != nil {
+		return fmt.Errorf("mlist init: %s", err)
+	}
+
 	if err := store.Init(mox.Context); err != nil {
 		return fmt.Errorf("store init: %s", err)
 	}
@@ -99,7 +117,20 @@ func start(mtastsdbRefresher, sendDMARCReports, sendTLSReports, skipForkExec boo
 		tlsrptsend.Start(dns.StrictResolver{Pkg: "tlsrptsend"})
 	}
 
+	postmasterdb.Start()
+	mlist.Start()
+
+	webops.JunkReevaluateStart(dns.StrictResolver{Pkg: "webops"})
+	webops.QuotaWarnStart()
+	store.CompressMessagesStart()
+	store.DedupCleanupStart()
+	admin.DNSProvisionStart()
+
 	store.StartAuthCache()
+	store.SenderJunkMarked = reputationdb.JunkMarked
+	if mox.Conf.Static.LDAP != nil {
+		store.ExternalAuth = ldap.Authenticator{}
+	}
 	smtpserver.Serve()
 	imapserver.Serve()
 	http.Serve()
@@ -408,10 +408,19 @@ Only implemented on unix systems, not Windows.
 		}
 	}
 
-	// Graceful shutdown.
+	// Graceful shutdown. Hangup reloads TLS certificates from files and certificate
+	// sources.
 	sigc := make(chan os.Signal, 1)
-	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sigc, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	sig := <-sigc
+	for sig == syscall.SIGHUP {
+		log.Print("reloading tls certificates after hangup signal")
+		ctx, cancel := context.WithTimeout(mox.Context, time.Minute)
+		_, err := mox.ReloadTLSCerts(ctx, log)
+		cancel()
+		log.Check(err, "reloading tls certificates")
+		sig = <-sigc
+	}
 	log.Print("shutting down, waiting max 3s for existing connections", slog.Any("signal", sig))
 	shutdown(log)
 	if num, ok := sig.(syscall.Signal); ok {
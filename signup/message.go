@@ -0,0 +1,100 @@
+package signup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// For tests.
+var queueAdd = queue.Add
+
+// sendMessage queues a plain text message from the postmaster address of domain
+// to the contact address of a signup. The message is DKIM-signed if the domain
+// has DKIM keys.
+func sendMessage(ctx context.Context, log mlog.Log, domain dns.Domain, to smtp.Address, subject, text string) error {
+	from := smtp.NewAddress("postmaster", domain)
+
+	mf, err := store.CreateMessageTemp(log, "signup-out")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, mf, "signup message")
+
+	msgPrefix, has8bit, smtputf8, messageID, err := composeMessage(ctx, log, mf, from, to, subject, text)
+	if err != nil {
+		return err
+	}
+	fi, err := mf.Stat()
+	if err != nil {
+		return fmt.Errorf("stat message file: %v", err)
+	}
+	size := int64(len(msgPrefix)) + fi.Size()
+
+	qm := queue.MakeMsg(from.Path(), to.Path(), has8bit, smtputf8, size, messageID, []byte(msgPrefix), nil, time.Now(), subject)
+	if err := queueAdd(ctx, log, mox.Conf.Static.Postmaster.Account, mf, qm); err != nil {
+		return fmt.Errorf("queueing message: %v", err)
+	}
+	return nil
+}
+
+func composeMessage(ctx context.Context, log mlog.Log, mf *os.File, from, to smtp.Address, subject, text string) (msgPrefix string, has8bit, smtputf8 bool, messageID string, rerr error) {
+	// We only use smtputf8 if we have to, with a utf-8 localpart. For IDNA, we use ASCII domains.
+	smtputf8 = to.Localpart.IsInternational()
+	xc := message.NewComposer(mf, 100*1024, smtputf8)
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(error); ok && errors.Is(err, message.ErrCompose) {
+			rerr = err
+			return
+		}
+		panic(x)
+	}()
+
+	xc.HeaderAddrs("From", []message.NameAddress{{Address: from}})
+	xc.HeaderAddrs("To", []message.NameAddress{{Address: to}})
+	xc.Subject(subject)
+	messageID = fmt.Sprintf("<%s>", mox.MessageIDGen(xc.SMTPUTF8))
+	xc.Header("Message-Id", messageID)
+	xc.Header("Date", time.Now().Format(message.RFC5322Z))
+	xc.Header("User-Agent", "mox/"+moxvar.Version)
+	xc.Header("Auto-Submitted", "auto-generated")
+	xc.Header("MIME-Version", "1.0")
+
+	textBody, ct, cte := xc.TextPart("plain", text)
+	xc.Header("Content-Type", ct)
+	xc.Header("Content-Transfer-Encoding", cte)
+	xc.Line()
+	_, err := xc.Write(textBody)
+	xc.Checkf(err, "writing text")
+
+	xc.Flush()
+
+	if confDom, ok := mox.Conf.Domain(from.Domain); ok {
+		if selectors := mox.DKIMSelectors(confDom.DKIM); len(selectors) > 0 {
+			dkimHeaders, err := dkim.Sign(ctx, log.Logger, from.Localpart, from.Domain, selectors, smtputf8, mf)
+			if err != nil {
+				log.Errorx("dkim-signing signup message, continuing without signature", err)
+			} else {
+				msgPrefix = dkimHeaders
+			}
+		}
+	}
+
+	return msgPrefix, xc.Has8bit, xc.SMTPUTF8, messageID, nil
+}
@@ -0,0 +1,743 @@
+/*
+Package signup implements self-service signup for accounts.
+
+The signup page is served at signup/ below the account web interface, for
+domains with Signup configured in domains.conf. A signup requires an invitation
+created by an admin, unless signups are open for the domain. The contact email
+address given in the signup form is verified by sending it a message with a
+link. After verification, the account is created, or, if the domain requires
+approval, the signup is waiting in the queue of requests in the admin web
+interface.
+
+Role addresses like postmaster and abuse, and the configured DMARC and TLS
+reporting addresses, cannot be requested.
+
+Signups are limited per remote IP, and can be verified with a captcha service,
+configured with Signup in mox.conf.
+*/
+package signup
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/admin"
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/ratelimit"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webauth"
+)
+
+var pkglog = mlog.New("signup", nil)
+
+// Time a signup has to verify its contact email address.
+const verifyTimeout = 24 * time.Hour
+
+// Signups per remote IP, with limits from the config.
+var limiterSignup = sync.OnceValue(func() *ratelimit.Limiter {
+	n := int64(5)
+	if sc := mox.Conf.Static.Signup; sc != nil && sc.MaxPerIPPerDay > 0 {
+		n = int64(sc.MaxPerIPPerDay)
+	}
+	return &ratelimit.Limiter{
+		WindowLimits: []ratelimit.WindowLimit{
+			{
+				Window: 24 * time.Hour,
+				Limits: [...]int64{n, 3 * n, 9 * n},
+			},
+		},
+	}
+})
+
+// Approving and rejecting requests is done one at a time.
+var decideMutex sync.Mutex
+
+// Domains returns the domains for which signup is configured, sorted.
+func Domains() []string {
+	var l []string
+	for _, name := range mox.Conf.Domains() {
+		if dc, ok := mox.Conf.Domain(dns.Domain{ASCII: name}); ok && dc.Signup != nil && !dc.Disabled {
+			l = append(l, name)
+		}
+	}
+	slices.Sort(l)
+	return l
+}
+
+// domainSignup returns the signup config of a domain, if enabled.
+func domainSignup(domain string) (dns.Domain, *config.DomainSignup, bool) {
+	d, err := dns.ParseDomain(domain)
+	if err != nil {
+		return dns.Domain{}, nil, false
+	}
+	dc, ok := mox.Conf.Domain(d)
+	if !ok || dc.Signup == nil || dc.Disabled {
+		return dns.Domain{}, nil, false
+	}
+	return d, dc.Signup, true
+}
+
+// Handler returns the handler for the signup pages, for requests with paths
+// starting with "/".
+func Handler(isForwarded bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handle(isForwarded, w, r)
+	})
+}
+
+// page holds the fields for the signup templates.
+type page struct {
+	Error        string
+	Message      string
+	Domains      []string
+	Invite       string
+	Localpart    string
+	Domain       string
+	FullName     string
+	ContactEmail string
+	CaptchaHTML  template.HTML
+	Token        string // For verification.
+	LoginURL     string // After account creation.
+}
+
+func handle(isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	ctx := context.WithValue(r.Context(), mlog.CidKey, mox.Cid())
+	log := pkglog.WithContext(ctx)
+
+	if len(Domains()) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	h.Set("X-Frame-Options", "deny")
+
+	switch r.URL.Path {
+	case "/":
+		switch r.Method {
+		case "GET", "HEAD":
+			p := formPage(r.URL.Query().Get("invite"))
+			render(log, w, http.StatusOK, signupTemplate, p)
+		case "POST":
+			submit(ctx, log, isForwarded, w, r)
+		default:
+			http.Error(w, "405 - method not allowed - use get or post", http.StatusMethodNotAllowed)
+		}
+	case "/verify":
+		// The GET only shows a button, so link scanners in mail systems don't verify.
+		switch r.Method {
+		case "GET", "HEAD":
+			render(log, w, http.StatusOK, verifyTemplate, page{Token: r.URL.Query().Get("token")})
+		case "POST":
+			verify(ctx, log, isForwarded, w, r)
+		default:
+			http.Error(w, "405 - method not allowed - use get or post", http.StatusMethodNotAllowed)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// formPage returns the page for the signup form, with the domains that can be
+// selected.
+func formPage(invite string) page {
+	p := page{Invite: invite}
+	if sc := mox.Conf.Static.Signup; sc != nil {
+		p.CaptchaHTML = template.HTML(sc.CaptchaHTML)
+	}
+	if invite == "" {
+		for _, name := range Domains() {
+			if _, ds, ok := domainSignup(name); ok && ds.Open {
+				p.Domains = append(p.Domains, name)
+			}
+		}
+		if len(p.Domains) == 0 {
+			p.Error = "signup requires an invitation"
+		}
+	}
+	return p
+}
+
+func render(log mlog.Log, w http.ResponseWriter, status int, t *template.Template, p page) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	err := t.Execute(w, p)
+	log.Check(err, "writing signup page")
+}
+
+// checkOrigin returns whether the request does not come from another site.
+func checkOrigin(isForwarded bool, r *http.Request) bool {
+	_, origin := webauth.RequestOrigin(isForwarded, r)
+	o := r.Header.Get("Origin")
+	return o == "" || o == origin
+}
+
+func submit(ctx context.Context, log mlog.Log, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(isForwarded, r) {
+		http.Error(w, "403 - forbidden - cross-origin request", http.StatusForbidden)
+		return
+	}
+	remoteIP := webauth.RemoteIP(log, isForwarded, r)
+	if remoteIP == nil {
+		http.Error(w, "500 - internal server error - cannot find remote ip", http.StatusInternalServerError)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 64*1024)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "400 - bad request - parsing form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	p := formPage(r.PostForm.Get("invite"))
+	p.Localpart = strings.TrimSpace(r.PostForm.Get("localpart"))
+	p.Domain = r.PostForm.Get("domain")
+	p.FullName = strings.TrimSpace(r.PostForm.Get("fullname"))
+	p.ContactEmail = strings.TrimSpace(r.PostForm.Get("contactemail"))
+
+	now := time.Now()
+	if !limiterSignup().CanAdd(remoteIP, now, 1) {
+		log.Info("refusing signup due to rate limit", slog.Any("remoteip", remoteIP))
+		p.Error = "too many signups, try again later"
+		render(log, w, http.StatusTooManyRequests, signupTemplate, p)
+		return
+	}
+
+	req, verifyToken, err := request(ctx, log, r, p, remoteIP)
+	if err != nil {
+		p.Error = err.Error()
+		render(log, w, http.StatusBadRequest, signupTemplate, p)
+		return
+	}
+	limiterSignup().Add(remoteIP, now, 1)
+
+	store.AuditAdd(ctx, log, store.AuditEvent{
+		Kind:     store.AuditAccount,
+		Account:  req.Account,
+		Protocol: "signup",
+		RemoteIP: remoteIP.String(),
+		Action:   "request",
+		Details:  req.Address,
+		Result:   "ok",
+	})
+
+	// Link to the verification page, relative to this page.
+	_, origin := webauth.RequestOrigin(isForwarded, r)
+	path := r.URL.Path
+	if u, err := url.ParseRequestURI(r.RequestURI); err == nil {
+		path = u.Path
+	}
+	link := origin + strings.TrimSuffix(path, "/") + "/verify?token=" + url.QueryEscape(verifyToken)
+	text := fmt.Sprintf(`Hi,
+
+Someone, hopefully you, requested an account with address:
+
+	%s
+
+This email address was given as contact address. To confirm the
+request, open:
+
+%s
+
+The link is valid for 24 hours. If you did not request an account, you can
+ignore this message.
+`, req.Address, link)
+	contact, _ := smtp.ParseAddress(req.ContactEmail)
+	addr, _ := smtp.ParseAddress(req.Address)
+	if err := sendMessage(ctx, log, addr.Domain, contact, "Confirm your account request for "+req.Address, text); err != nil {
+		log.Errorx("sending signup verification message", err)
+		p.Error = "could not send the verification message, try again later"
+		render(log, w, http.StatusInternalServerError, signupTemplate, p)
+		return
+	}
+
+	log.Info("signup requested", slog.String("address", req.Address), slog.String("contact", req.ContactEmail))
+	render(log, w, http.StatusOK, signupTemplate, page{Message: "A message with a confirmation link has been sent to " + req.ContactEmail + "."})
+}
+
+// request checks and adds a signup request from a form, returning the
+// verification token. Errors are shown to the user.
+func request(ctx context.Context, log mlog.Log, r *http.Request, p page, remoteIP net.IP) (store.SignupRequest, string, error) {
+	if p.Error != "" {
+		return store.SignupRequest{}, "", errors.New(p.Error)
+	}
+
+	if sc := mox.Conf.Static.Signup; sc != nil && sc.CaptchaVerifyURL != "" {
+		if err := captchaVerify(ctx, log, sc, r, remoteIP); err != nil {
+			return store.SignupRequest{}, "", err
+		}
+	}
+
+	var inviteID int64
+	if p.Invite != "" {
+		si, err := store.SignupInviteGet(ctx, p.Invite)
+		if err != nil {
+			log.Debugx("looking up signup invitation", err)
+			return store.SignupRequest{}, "", errInvite
+		}
+		inviteID = si.ID
+		p.Domain = si.Domain
+	} else if !slices.Contains(p.Domains, p.Domain) {
+		return store.SignupRequest{}, "", errDomain
+	}
+	d, _, ok := domainSignup(p.Domain)
+	if !ok {
+		return store.SignupRequest{}, "", errDomain
+	}
+
+	lp, err := smtp.ParseLocalpart(p.Localpart)
+	if err != nil || p.Localpart == "" || strings.Contains(p.Localpart, "/") {
+		return store.SignupRequest{}, "", fmt.Errorf(`invalid username, use letters, digits and characters like "." and "-"`)
+	}
+	addr := smtp.NewAddress(lp, d)
+	accountName := strings.ToLower(string(lp))
+	if reservedLocalpart(d, lp) {
+		log.Debug("reserved address requested for signup", slog.Any("address", addr))
+		return store.SignupRequest{}, "", fmt.Errorf("address %s is not available", addr)
+	}
+	if _, ok := mox.Conf.Account(accountName); ok {
+		return store.SignupRequest{}, "", fmt.Errorf("address %s is not available", addr)
+	}
+	if err := admin.AddressAvailable(addr); err != nil {
+		log.Debugx("address for signup not available", err, slog.Any("address", addr))
+		return store.SignupRequest{}, "", fmt.Errorf("address %s is not available", addr)
+	}
+
+	contact, err := smtp.ParseAddress(p.ContactEmail)
+	if err != nil {
+		return store.SignupRequest{}, "", fmt.Errorf("invalid contact email address")
+	} else if _, ok := mox.Conf.Domain(contact.Domain); ok {
+		return store.SignupRequest{}, "", fmt.Errorf("contact email address must be at another domain")
+	}
+
+	password := r.PostForm.Get("password")
+	if password != r.PostForm.Get("password2") {
+		return store.SignupRequest{}, "", fmt.Errorf("passwords do not match")
+	}
+	pw, err := store.MakePassword(password)
+	if err != nil {
+		return store.SignupRequest{}, "", fmt.Errorf("password not accepted: %v", err)
+	}
+
+	token, hash := store.SignupToken()
+	req := store.SignupRequest{
+		State:           store.SignupUnverified,
+		Account:         accountName,
+		Address:         addr.String(),
+		FullName:        p.FullName,
+		ContactEmail:    contact.String(),
+		InviteID:        inviteID,
+		RemoteIP:        remoteIP.String(),
+		VerifyTokenHash: hash,
+		Password:        pw,
+	}
+	err = store.AuthDB.Write(ctx, func(tx *bstore.Tx) error {
+		// Only one open request per account name or address.
+		q := bstore.QueryTx[store.SignupRequest](tx)
+		q.FilterEqual("State", store.SignupUnverified, store.SignupPending)
+		q.FilterFn(func(o store.SignupRequest) bool {
+			return o.Account == req.Account || o.Address == req.Address
+		})
+		if exists, err := q.Exists(); err != nil {
+			return err
+		} else if exists {
+			return errRequested
+		}
+		return tx.Insert(&req)
+	})
+	if errors.Is(err, errRequested) {
+		return store.SignupRequest{}, "", err
+	} else if err != nil {
+		log.Errorx("adding signup request", err)
+		return store.SignupRequest{}, "", errInternal
+	}
+	return req, token, nil
+}
+
+// Role addresses that cannot be requested through signup, RFC 2142 and addresses
+// typically used by mail systems.
+var reservedLocalparts = []string{"postmaster", "abuse", "hostmaster", "webmaster", "mailer-daemon", "noc", "security", "root"}
+
+// reservedLocalpart returns whether localpart lp at domain d is reserved: a role
+// address, or a configured DMARC or TLS reporting address. An account with such
+// an address would receive messages meant for the operators, an account
+// destination takes precedence over delivery to the postmaster account. And
+// messages about signups are sent from the postmaster address.
+func reservedLocalpart(d dns.Domain, lp smtp.Localpart) bool {
+	dc, ok := mox.Conf.Domain(d)
+	if !ok {
+		return true
+	}
+	clp := string(mox.CanonicalLocalpart(lp, dc))
+	if slices.Contains(reservedLocalparts, strings.ToLower(clp)) {
+		return true
+	}
+	// Reporting addresses can be configured at another domain.
+	for _, odc := range mox.Conf.DomainConfigs() {
+		if odc.DMARC != nil && odc.DMARC.DNSDomain == d && strings.EqualFold(string(odc.DMARC.ParsedLocalpart), clp) {
+			return true
+		}
+		if odc.TLSRPT != nil && odc.TLSRPT.DNSDomain == d && strings.EqualFold(string(odc.TLSRPT.ParsedLocalpart), clp) {
+			return true
+		}
+	}
+	hr := mox.Conf.Static.HostTLSRPT
+	return d == mox.Conf.Static.HostnameDomain && hr.Localpart != "" && strings.EqualFold(string(hr.ParsedLocalpart), clp)
+}
+
+// Errors shown to users.
+var (
+	errInvite    = errors.New("invitation is not valid or has expired")
+	errDomain    = errors.New("signup is not possible for this domain")
+	errRequested = errors.New("address has already been requested")
+	errInternal  = errors.New("internal error, try again later")
+	errCaptcha   = errors.New("could not verify captcha, try again later")
+)
+
+// captchaVerify checks the captcha response from the form with the captcha
+// service.
+func captchaVerify(ctx context.Context, log mlog.Log, sc *config.Signup, r *http.Request, remoteIP net.IP) error {
+	var response string
+	for _, k := range []string{"h-captcha-response", "g-recaptcha-response", "cf-turnstile-response", "captcha-response"} {
+		if response = r.PostForm.Get(k); response != "" {
+			break
+		}
+	}
+	if response == "" {
+		return fmt.Errorf("missing captcha response")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	form := url.Values{"secret": {sc.CaptchaSecret}, "response": {response}, "remoteip": {remoteIP.String()}}
+	hreq, err := http.NewRequestWithContext(ctx, "POST", sc.CaptchaVerifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		log.Errorx("making captcha verify request", err)
+		return errCaptcha
+	}
+	hreq.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(hreq)
+	if err != nil {
+		log.Errorx("captcha verify request", err)
+		return errCaptcha
+	}
+	defer resp.Body.Close()
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil || resp.StatusCode/100 != 2 {
+		log.Errorx("captcha verify response", err, slog.Int("status", resp.StatusCode))
+		return errCaptcha
+	}
+	if !result.Success {
+		return fmt.Errorf("captcha not solved, try again")
+	}
+	return nil
+}
+
+func verify(ctx context.Context, log mlog.Log, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	if !checkOrigin(isForwarded, r) {
+		http.Error(w, "403 - forbidden - cross-origin request", http.StatusForbidden)
+		return
+	}
+	remoteIP := webauth.RemoteIP(log, isForwarded, r)
+	if remoteIP == nil {
+		http.Error(w, "500 - internal server error - cannot find remote ip", http.StatusInternalServerError)
+		return
+	}
+	t0 := time.Now()
+	if !mox.LimiterFailedAuth.CanAdd(remoteIP, t0, 1) {
+		http.Error(w, "429 - too many attempts", http.StatusTooManyRequests)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, 1024)
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "400 - bad request - parsing form: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req, err := Verify(ctx, log, r.PostForm.Get("token"))
+	if err != nil {
+		if errors.Is(err, store.ErrSignupToken) {
+			mox.LimiterFailedAuth.Add(remoteIP, t0, 1)
+		}
+		render(log, w, http.StatusBadRequest, verifyTemplate, page{Error: err.Error()})
+		return
+	}
+	store.AuditAdd(ctx, log, store.AuditEvent{
+		Kind:     store.AuditAccount,
+		Account:  req.Account,
+		Protocol: "signup",
+		RemoteIP: remoteIP.String(),
+		Action:   "verify",
+		Details:  string(req.State),
+		Result:   "ok",
+	})
+	if req.State == store.SignupApproved {
+		render(log, w, http.StatusOK, verifyTemplate, page{Message: "Your account has been created. You can now log in with address " + req.Address + " and your password.", LoginURL: "../"})
+	} else {
+		render(log, w, http.StatusOK, verifyTemplate, page{Message: "Your email address has been verified. Your account request will be reviewed, you will receive a message at your contact address when it has been approved."})
+	}
+}
+
+// Verify marks the signup request with the verification token as verified. If
+// the domain does not require approval, the account is created. If an
+// invitation was used, it is counted as used.
+func Verify(ctx context.Context, log mlog.Log, token string) (store.SignupRequest, error) {
+	var req store.SignupRequest
+	var requireApproval bool
+	err := store.AuthDB.Write(ctx, func(tx *bstore.Tx) error {
+		q := bstore.QueryTx[store.SignupRequest](tx)
+		q.FilterNonzero(store.SignupRequest{VerifyTokenHash: store.SignupTokenHash(token), State: store.SignupUnverified})
+		var err error
+		req, err = q.Get()
+		if err == bstore.ErrAbsent || err == nil && time.Since(req.Created) > verifyTimeout {
+			return store.ErrSignupToken
+		} else if err != nil {
+			return err
+		}
+
+		addr, err := smtp.ParseAddress(req.Address)
+		if err != nil {
+			return fmt.Errorf("parsing address: %v", err)
+		}
+		_, ds, ok := domainSignup(addr.Domain.Name())
+		if !ok {
+			return errDomain
+		}
+		requireApproval = ds.RequireApproval
+
+		if req.InviteID != 0 {
+			si := store.SignupInvite{ID: req.InviteID}
+			if err := tx.Get(&si); err == bstore.ErrAbsent || err == nil && (time.Until(si.Expires) < 0 || si.Uses >= si.MaxUses) {
+				return errInvite
+			} else if err != nil {
+				return err
+			}
+			si.Uses++
+			if err := tx.Update(&si); err != nil {
+				return err
+			}
+		}
+
+		req.State = store.SignupPending
+		req.Verified = time.Now()
+		req.VerifyTokenHash = ""
+		return tx.Update(&req)
+	})
+	if errors.Is(err, errDomain) || errors.Is(err, errInvite) || errors.Is(err, store.ErrSignupToken) {
+		return store.SignupRequest{}, err
+	} else if err != nil {
+		log.Errorx("verifying signup request", err)
+		return store.SignupRequest{}, errInternal
+	}
+	log.Info("signup verified", slog.String("address", req.Address), slog.Bool("requireapproval", requireApproval))
+
+	if requireApproval {
+		return req, nil
+	}
+	req, err = Approve(ctx, log, req.ID, "")
+	if err != nil {
+		return store.SignupRequest{}, fmt.Errorf("creating account failed, an admin will review your request")
+	}
+	return req, nil
+}
+
+// Approve creates the account for a verified signup request. Actor is the
+// admin approving, or empty if approved automatically after verification. The
+// contact email address is notified for approvals by an admin.
+func Approve(ctx context.Context, log mlog.Log, id int64, actor string) (req store.SignupRequest, rerr error) {
+	decideMutex.Lock()
+	defer decideMutex.Unlock()
+
+	defer func() {
+		if rerr != nil {
+			log.Errorx("approving signup request", rerr, slog.Int64("id", id))
+		}
+	}()
+
+	req = store.SignupRequest{ID: id}
+	if err := store.AuthDB.Get(ctx, &req); err != nil {
+		return req, fmt.Errorf("%w: get signup request: %v", admin.ErrRequest, err)
+	} else if req.State != store.SignupPending {
+		return req, fmt.Errorf("%w: signup request is %s, not pending", admin.ErrRequest, req.State)
+	}
+	addr, err := smtp.ParseAddress(req.Address)
+	if err != nil {
+		return req, fmt.Errorf("parsing address: %v", err)
+	}
+
+	if err := admin.AccountAdd(ctx, req.Account, req.Address); err != nil {
+		return req, err
+	}
+	var quota int64
+	if _, ds, ok := domainSignup(addr.Domain.Name()); ok {
+		quota = ds.QuotaMessageSize
+	}
+	if req.FullName != "" || quota != 0 {
+		err := admin.AccountSave(ctx, req.Account, func(acc *config.Account) {
+			acc.FullName = req.FullName
+			acc.QuotaMessageSize = quota
+		})
+		if err != nil {
+			return req, fmt.Errorf("saving account settings: %w", err)
+		}
+	}
+	if err := accountPassword(log, req.Account, req.Password); err != nil {
+		return req, err
+	}
+
+	req.State = store.SignupApproved
+	req.Decided = time.Now()
+	req.DecidedBy = actor
+	req.Password = store.Password{}
+	if err := store.AuthDB.Update(ctx, &req); err != nil {
+		return req, fmt.Errorf("updating signup request: %v", err)
+	}
+	log.Info("signup approved, account created", slog.String("account", req.Account), slog.String("address", req.Address), slog.String("actor", actor))
+
+	if actor != "" {
+		contact, _ := smtp.ParseAddress(req.ContactEmail)
+		text := fmt.Sprintf(`Hi,
+
+Your request for account %s has been approved. You can now log in with this
+address and the password you chose during signup.
+`, req.Address)
+		err := sendMessage(ctx, log, addr.Domain, contact, "Account "+req.Address+" approved", text)
+		log.Check(err, "sending signup approval message")
+	}
+	return req, nil
+}
+
+func accountPassword(log mlog.Log, accountName string, pw store.Password) error {
+	acc, err := store.OpenAccount(log, accountName, false)
+	if err != nil {
+		return fmt.Errorf("open account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account after setting password")
+	}()
+	if err := acc.SetPasswordHashes(log, pw); err != nil {
+		return fmt.Errorf("setting password: %v", err)
+	}
+	return nil
+}
+
+// Reject rejects a signup request that is pending or not yet verified. If the
+// contact email address was verified, it is notified, with reason if not empty.
+func Reject(ctx context.Context, log mlog.Log, id int64, actor, reason string) (rerr error) {
+	decideMutex.Lock()
+	defer decideMutex.Unlock()
+
+	req := store.SignupRequest{ID: id}
+	if err := store.AuthDB.Get(ctx, &req); err != nil {
+		return fmt.Errorf("%w: get signup request: %v", admin.ErrRequest, err)
+	} else if req.State != store.SignupPending && req.State != store.SignupUnverified {
+		return fmt.Errorf("%w: signup request is %s", admin.ErrRequest, req.State)
+	}
+	notify := req.State == store.SignupPending
+
+	req.State = store.SignupRejected
+	req.Decided = time.Now()
+	req.DecidedBy = actor
+	req.RejectReason = reason
+	req.VerifyTokenHash = ""
+	req.Password = store.Password{}
+	if err := store.AuthDB.Update(ctx, &req); err != nil {
+		return fmt.Errorf("updating signup request: %v", err)
+	}
+	log.Info("signup rejected", slog.String("address", req.Address), slog.String("actor", actor))
+
+	if notify {
+		addr, _ := smtp.ParseAddress(req.Address)
+		contact, _ := smtp.ParseAddress(req.ContactEmail)
+		text := fmt.Sprintf(`Hi,
+
+Your request for account %s has been rejected.
+`, req.Address)
+		if reason != "" {
+			text += "\nReason: " + reason + "\n"
+		}
+		err := sendMessage(ctx, log, addr.Domain, contact, "Account request for "+req.Address+" rejected", text)
+		log.Check(err, "sending signup rejection message")
+	}
+	return nil
+}
+
+const pageStyle = `<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+label { display: block; margin: 1ex 0; }
+input { width: 100%; box-sizing: border-box; }
+.error { background-color: #ff7443; padding: .5em; border-radius: 3px; }
+.message { background-color: #1dea20; padding: .5em; border-radius: 3px; }
+</style>`
+
+var signupTemplate = template.Must(template.New("signup").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<meta name="viewport" content="width=device-width, initial-scale=1" />
+		<title>Sign up</title>
+		` + pageStyle + `
+	</head>
+	<body>
+		<h1>Sign up</h1>
+		{{ if .Error }}<p class="error">Error: {{ .Error }}</p>{{ end }}
+		{{ if .Message }}<p class="message">{{ .Message }}</p>{{ else if or .Invite .Domains }}
+		<form method="post" action="./">
+			<input type="hidden" name="invite" value="{{ .Invite }}" />
+			<label>Username<br/><input name="localpart" value="{{ .Localpart }}" required autocomplete="username" /></label>
+			{{ if not .Invite }}<label>Domain<br/><select name="domain">{{ range .Domains }}<option{{ if eq . $.Domain }} selected{{ end }}>{{ . }}</option>{{ end }}</select></label>{{ end }}
+			<label>Full name<br/><input name="fullname" value="{{ .FullName }}" autocomplete="name" /></label>
+			<label>Contact email address, at another domain, for confirming the request<br/><input name="contactemail" type="email" value="{{ .ContactEmail }}" required autocomplete="email" /></label>
+			<label>Password<br/><input name="password" type="password" required minlength="8" autocomplete="new-password" /></label>
+			<label>Repeat password<br/><input name="password2" type="password" required minlength="8" autocomplete="new-password" /></label>
+			{{ .CaptchaHTML }}
+			<p><button type="submit">Request account</button></p>
+		</form>
+		{{ end }}
+	</body>
+</html>
+`))
+
+var verifyTemplate = template.Must(template.New("verify").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<meta name="viewport" content="width=device-width, initial-scale=1" />
+		<title>Confirm account request</title>
+		` + pageStyle + `
+	</head>
+	<body>
+		<h1>Confirm account request</h1>
+		{{ if .Error }}<p class="error">Error: {{ .Error }}</p>{{ end }}
+		{{ if .Message }}<p class="message">{{ .Message }}</p>{{ if .LoginURL }}<p><a href="{{ .LoginURL }}">Log in</a></p>{{ end }}{{ else if .Token }}
+		<form method="post" action="verify">
+			<input type="hidden" name="token" value="{{ .Token }}" />
+			<p><button type="submit">Confirm</button></p>
+		</form>
+		{{ end }}
+	</body>
+</html>
+`))
@@ -0,0 +1,270 @@
+package signup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/admin"
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func TestSignup(t *testing.T) {
+	dir := t.TempDir()
+	mox.ConfigStaticPath = filepath.Join(dir, "mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(dir, "domains.conf")
+	for _, name := range []string{"mox.conf", "domains.conf"} {
+		buf, err := os.ReadFile(filepath.FromSlash("../testdata/store/" + name))
+		tcheck(t, err, "read config")
+		err = os.WriteFile(filepath.Join(dir, name), buf, 0660)
+		tcheck(t, err, "write config")
+	}
+	mox.MustLoadConfig(true, false)
+	mox.Conf.Static.Signup = &config.Signup{MaxPerIPPerDay: 100}
+	mox.LimitersInit()
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+
+	// Capture the queued messages.
+	var messages []string
+	queueAdd = func(ctx context.Context, log mlog.Log, senderAccount string, msgFile *os.File, qml ...queue.Msg) error {
+		buf, err := io.ReadAll(&moxio.AtReader{R: msgFile})
+		tcheck(t, err, "read message")
+		// Long lines cause quoted-printable encoding, decode so we can find tokens.
+		if strings.Contains(string(buf), "Content-Transfer-Encoding: quoted-printable") {
+			buf, err = io.ReadAll(quotedprintable.NewReader(bytes.NewReader(buf)))
+			tcheck(t, err, "decode quoted-printable")
+		}
+		messages = append(messages, qml[0].Recipient().String()+"\n"+string(qml[0].MsgPrefix)+string(buf))
+		return nil
+	}
+	defer func() {
+		queueAdd = queue.Add
+	}()
+
+	handler := Handler(false)
+	do := func(method, path string, form url.Values, expStatus int, expText string) string {
+		t.Helper()
+		var body io.Reader
+		if form != nil {
+			body = strings.NewReader(form.Encode())
+		}
+		req := httptest.NewRequest(method, path, body)
+		if form != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		resp := rec.Body.String()
+		if rec.Code != expStatus || !strings.Contains(resp, expText) {
+			t.Fatalf("%s %s: got status %d, expected %d, with %q in response:\n%s", method, path, rec.Code, expStatus, expText, resp)
+		}
+		return resp
+	}
+
+	// Not configured for any domain.
+	do("GET", "/", nil, http.StatusNotFound, "")
+
+	err = admin.DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.Signup = &config.DomainSignup{Open: true, QuotaMessageSize: 1000000}
+		return nil
+	})
+	tcheck(t, err, "enable signup")
+
+	do("GET", "/", nil, http.StatusOK, "<option>mox.example</option>")
+
+	form := func(localpart, contact string) url.Values {
+		return url.Values{
+			"localpart":    {localpart},
+			"domain":       {"mox.example"},
+			"fullname":     {"New User"},
+			"contactemail": {contact},
+			"password":     {"testtest123"},
+			"password2":    {"testtest123"},
+		}
+	}
+	tokenRegexp := regexp.MustCompile(`verify\?token=([a-zA-Z0-9_-]+)`)
+	lastToken := func() string {
+		t.Helper()
+		m := tokenRegexp.FindStringSubmatch(messages[len(messages)-1])
+		if m == nil {
+			t.Fatalf("no token in message:\n%s", messages[len(messages)-1])
+		}
+		return m[1]
+	}
+
+	// Bad requests.
+	do("POST", "/", form("mjl", "user@other.example"), http.StatusBadRequest, "not available")
+	do("POST", "/", form("new", "user@mox.example"), http.StatusBadRequest, "another domain")
+	f := form("new", "user@other.example")
+	f.Set("password2", "other")
+	do("POST", "/", f, http.StatusBadRequest, "do not match")
+	f = form("new", "user@other.example")
+	f.Set("domain", "other.example")
+	do("POST", "/", f, http.StatusBadRequest, "not possible for this domain")
+
+	// Role and reporting addresses are reserved.
+	dc := mox.Conf.Dynamic.Domains["mox.example"]
+	dc.DMARC = &config.DMARC{Localpart: "dmarcreports", ParsedLocalpart: "dmarcreports", DNSDomain: dns.Domain{ASCII: "mox.example"}}
+	mox.Conf.Dynamic.Domains["mox.example"] = dc
+	for _, lp := range []string{"postmaster", "Abuse", "hostmaster", "webmaster", "mailer-daemon", "DMARCReports"} {
+		do("POST", "/", form(lp, "user@other.example"), http.StatusBadRequest, "not available")
+	}
+	dc.DMARC = nil
+	mox.Conf.Dynamic.Domains["mox.example"] = dc
+
+	if len(messages) != 0 {
+		t.Fatalf("messages sent for bad requests")
+	}
+
+	// Open signup, account is created after verification.
+	do("POST", "/", form("new", "user@other.example"), http.StatusOK, "confirmation link has been sent")
+	if len(messages) != 1 || !strings.HasPrefix(messages[0], "user@other.example\n") {
+		t.Fatalf("unexpected messages: %v", messages)
+	}
+	do("POST", "/", form("new", "user2@other.example"), http.StatusBadRequest, "already been requested")
+	token := lastToken()
+	do("GET", "/verify?token="+token, nil, http.StatusOK, `value="`+token+`"`)
+	do("POST", "/verify", url.Values{"token": {"bogus"}}, http.StatusBadRequest, "unknown or expired token")
+	do("POST", "/verify", url.Values{"token": {token}}, http.StatusOK, "account has been created")
+	do("POST", "/verify", url.Values{"token": {token}}, http.StatusBadRequest, "unknown or expired token")
+	accConf, ok := mox.Conf.Account("new")
+	if !ok || accConf.FullName != "New User" || accConf.QuotaMessageSize != 1000000 {
+		t.Fatalf("account not created as expected: %v %#v", ok, accConf)
+	}
+	acc, _, err := store.OpenEmailAuth(pkglog, "new@mox.example", "testtest123", "test", nil, false)
+	tcheck(t, err, "login with password from signup")
+	err = acc.Close()
+	tcheck(t, err, "close account")
+	acc.WaitClosed()
+
+	// Invitation required, with approval.
+	err = admin.DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.Signup = &config.DomainSignup{RequireApproval: true}
+		return nil
+	})
+	tcheck(t, err, "change signup config")
+	do("GET", "/", nil, http.StatusOK, "requires an invitation")
+	do("POST", "/", form("second", "user@other.example"), http.StatusBadRequest, "requires an invitation")
+
+	invite, err := store.SignupInviteAdd(ctxbg, "mox.example", 1, time.Now().Add(time.Hour), "test", "admin")
+	tcheck(t, err, "add invite")
+	do("GET", "/?invite="+invite, nil, http.StatusOK, `name="invite" value="`+invite+`"`)
+	f = form("second", "user@other.example")
+	f.Set("invite", invite)
+	do("POST", "/", f, http.StatusOK, "confirmation link has been sent")
+	f = form("third", "third@other.example")
+	f.Set("invite", invite)
+	do("POST", "/", f, http.StatusOK, "confirmation link has been sent")
+	thirdToken := lastToken()
+	do("POST", "/verify", url.Values{"token": {lastTokenAt(t, tokenRegexp, messages, len(messages)-2)}}, http.StatusOK, "will be reviewed")
+	// Invitation can only be used once.
+	do("POST", "/verify", url.Values{"token": {thirdToken}}, http.StatusBadRequest, "invitation is not valid")
+
+	pending, err := store.SignupRequestList(ctxbg, store.SignupPending)
+	tcheck(t, err, "list pending requests")
+	if len(pending) != 1 || pending[0].Account != "second" {
+		t.Fatalf("unexpected pending requests: %#v", pending)
+	}
+	nmsg := len(messages)
+	req, err := Approve(ctxbg, pkglog, pending[0].ID, "(admin)")
+	tcheck(t, err, "approve")
+	if req.State != store.SignupApproved || len(messages) != nmsg+1 {
+		t.Fatalf("unexpected state %q or no notification", req.State)
+	}
+	if _, ok := mox.Conf.Account("second"); !ok {
+		t.Fatalf("account not created after approval")
+	}
+	if _, err := Approve(ctxbg, pkglog, pending[0].ID, "(admin)"); err == nil {
+		t.Fatalf("approved twice")
+	}
+
+	// Reject a request.
+	invite, err = store.SignupInviteAdd(ctxbg, "mox.example", 1, time.Now().Add(time.Hour), "", "admin")
+	tcheck(t, err, "add invite")
+	f = form("fourth", "fourth@other.example")
+	f.Set("invite", invite)
+	do("POST", "/", f, http.StatusOK, "confirmation link has been sent")
+	do("POST", "/verify", url.Values{"token": {lastToken()}}, http.StatusOK, "will be reviewed")
+	pending, err = store.SignupRequestList(ctxbg, store.SignupPending)
+	tcheck(t, err, "list pending requests")
+	err = Reject(ctxbg, pkglog, pending[0].ID, "(admin)", "testing")
+	tcheck(t, err, "reject")
+	if !strings.Contains(messages[len(messages)-1], "Reason: testing") {
+		t.Fatalf("rejection message without reason:\n%s", messages[len(messages)-1])
+	}
+	if _, ok := mox.Conf.Account("fourth"); ok {
+		t.Fatalf("account created for rejected request")
+	}
+
+	// Captcha.
+	var captchaSuccess bool
+	captchaServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") != "secret" || r.FormValue("response") != "solved" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintf(w, `{"success": %v}`, captchaSuccess)
+	}))
+	defer captchaServer.Close()
+	mox.Conf.Static.Signup.CaptchaVerifyURL = captchaServer.URL
+	mox.Conf.Static.Signup.CaptchaSecret = "secret"
+	invite, err = store.SignupInviteAdd(ctxbg, "mox.example", 1, time.Now().Add(time.Hour), "", "admin")
+	tcheck(t, err, "add invite")
+	f = form("fifth", "fifth@other.example")
+	f.Set("invite", invite)
+	do("POST", "/", f, http.StatusBadRequest, "missing captcha response")
+	f.Set("h-captcha-response", "solved")
+	do("POST", "/", f, http.StatusBadRequest, "captcha not solved")
+	captchaSuccess = true
+	do("POST", "/", f, http.StatusOK, "confirmation link has been sent")
+
+	// Cross-origin form posts are refused.
+	req2 := httptest.NewRequest("POST", "/", strings.NewReader(f.Encode()))
+	req2.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req2.Header.Set("Origin", "https://attacker.example")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req2)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("cross-origin post: got status %d, expected 403", rec.Code)
+	}
+}
+
+func lastTokenAt(t *testing.T, re *regexp.Regexp, messages []string, i int) string {
+	t.Helper()
+	m := re.FindStringSubmatch(messages[i])
+	if m == nil {
+		t.Fatalf("no token in message:\n%s", messages[i])
+	}
+	return m[1]
+}
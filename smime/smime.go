@@ -0,0 +1,354 @@
+// Package smime verifies S/MIME signatures of messages, RFC 8551.
+//
+// Only detached signatures are verified, i.e. multipart/signed messages with an
+// application/pkcs7-signature part containing a CMS SignedData structure, RFC
+// 5652, without the signed content.
+package smime
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/smtp"
+)
+
+// Verify errors.
+var (
+	ErrSyntax      = errors.New("smime: malformed signature")
+	ErrUnsupported = errors.New("smime: unsupported signature")
+	ErrNoSigner    = errors.New("smime: signer certificate not found")
+	ErrSignature   = errors.New("smime: bad signature")
+	ErrCertificate = errors.New("smime: certificate not trusted")
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidEmailAddress  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 1}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidRSA             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidSHA1WithRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 5}
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidSHA384WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 12}
+	oidSHA512WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 13}
+	oidECPublicKey     = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+	oidECDSAWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 3}
+	oidECDSAWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 4}
+	oidEd25519         = asn1.ObjectIdentifier{1, 3, 101, 112}
+)
+
+// ../rfc/5652:284
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,tag:0"`
+}
+
+// ../rfc/5652:340
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+// ../rfc/5652:382
+type encapContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"optional,explicit,tag:0"`
+}
+
+// ../rfc/5652:478
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// Result is the result of a verified signature.
+type Result struct {
+	Signer *x509.Certificate   // Certificate that made the signature.
+	Chain  []*x509.Certificate // Verified chain from signer to trusted root.
+}
+
+// Verify checks the DER-encoded detached CMS signature sig over data, and verifies
+// the certificate of the signer for email protection against roots at time now.
+// If roots is nil, the system roots are used.
+//
+// If the signature is valid but the certificate cannot be verified, the returned
+// error is ErrCertificate and the signer certificate is set in the result.
+func Verify(data, sig []byte, roots *x509.CertPool, now time.Time) (Result, error) {
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(sig, &ci); err != nil {
+		return Result{}, fmt.Errorf("%w: parsing content info: %v", ErrSyntax, err)
+	} else if len(rest) > 0 {
+		return Result{}, fmt.Errorf("%w: trailing data after content info", ErrSyntax)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return Result{}, fmt.Errorf("%w: content type %s, need signed data", ErrUnsupported, ci.ContentType)
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return Result{}, fmt.Errorf("%w: parsing signed data: %v", ErrSyntax, err)
+	}
+	if len(sd.EncapContentInfo.EContent.Bytes) > 0 {
+		return Result{}, fmt.Errorf("%w: signature contains content, only detached signatures are supported", ErrUnsupported)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return Result{}, fmt.Errorf("%w: %d signers, need exactly 1", ErrUnsupported, len(sd.SignerInfos))
+	}
+	var certs []*x509.Certificate
+	if len(sd.Certificates.Bytes) > 0 {
+		var err error
+		certs, err = x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return Result{}, fmt.Errorf("%w: parsing certificates: %v", ErrSyntax, err)
+		}
+	}
+
+	si := sd.SignerInfos[0]
+	signer, err := findSigner(si.SID, certs)
+	if err != nil {
+		return Result{}, err
+	}
+
+	hash, err := digestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return Result{}, err
+	}
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	// Without signed attributes, the signature is over the content itself. With signed
+	// attributes, they must include the digest of the content, and the signature is
+	// over the DER-encoded attributes. ../rfc/5652:557
+	signed := data
+	if len(si.SignedAttrs.FullBytes) > 0 {
+		buf := bytes.Clone(si.SignedAttrs.FullBytes)
+		buf[0] = 0x31 // SET OF instead of the implicit [0] tag. ../rfc/5652:591
+		var attrs []attribute
+		if _, err := asn1.UnmarshalWithParams(buf, &attrs, "set"); err != nil {
+			return Result{}, fmt.Errorf("%w: parsing signed attributes: %v", ErrSyntax, err)
+		}
+		var haveType, haveDigest bool
+		for _, a := range attrs {
+			if len(a.Values) != 1 {
+				continue
+			}
+			switch {
+			case a.Type.Equal(oidContentType):
+				var oid asn1.ObjectIdentifier
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &oid); err != nil || !oid.Equal(sd.EncapContentInfo.EContentType) {
+					return Result{}, fmt.Errorf("%w: bad content type attribute", ErrSyntax)
+				}
+				haveType = true
+			case a.Type.Equal(oidMessageDigest):
+				var md []byte
+				if _, err := asn1.Unmarshal(a.Values[0].FullBytes, &md); err != nil {
+					return Result{}, fmt.Errorf("%w: parsing message digest attribute: %v", ErrSyntax, err)
+				}
+				if !bytes.Equal(md, digest) {
+					return Result{Signer: signer}, fmt.Errorf("%w: message digest mismatch, message was modified", ErrSignature)
+				}
+				haveDigest = true
+			}
+		}
+		if !haveType || !haveDigest {
+			return Result{}, fmt.Errorf("%w: missing content type or message digest attribute", ErrSyntax)
+		}
+		signed = buf
+	} else if !sd.EncapContentInfo.EContentType.Equal(oidData) {
+		return Result{}, fmt.Errorf("%w: signed attributes required for content type %s", ErrSyntax, sd.EncapContentInfo.EContentType)
+	}
+
+	algo, err := signatureAlgorithm(si.SignatureAlgorithm.Algorithm, si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := signer.CheckSignature(algo, signed, si.Signature); err != nil {
+		return Result{Signer: signer}, fmt.Errorf("%w: %v", ErrSignature, err)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		if c != signer {
+			intermediates.AddCert(c)
+		}
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+	}
+	chains, err := signer.Verify(opts)
+	if err != nil {
+		return Result{Signer: signer}, fmt.Errorf("%w: %v", ErrCertificate, err)
+	}
+	return Result{signer, chains[0]}, nil
+}
+
+// findSigner returns the certificate identified by sid, by issuer and serial
+// number, or by subject key identifier. ../rfc/5652:518
+func findSigner(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence {
+		var ias issuerAndSerialNumber
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("%w: parsing signer identifier: %v", ErrSyntax, err)
+		}
+		for _, c := range certs {
+			if bytes.Equal(c.RawIssuer, ias.Issuer.FullBytes) && c.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+				return c, nil
+			}
+		}
+	} else if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, c := range certs {
+			if len(c.SubjectKeyId) > 0 && bytes.Equal(c.SubjectKeyId, sid.Bytes) {
+				return c, nil
+			}
+		}
+	} else {
+		return nil, fmt.Errorf("%w: unknown signer identifier", ErrSyntax)
+	}
+	return nil, ErrNoSigner
+}
+
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("%w: digest algorithm %s", ErrUnsupported, oid)
+}
+
+// signatureAlgorithm returns the x509 signature algorithm for a signature
+// algorithm, which can also be just the public key algorithm, with the digest
+// algorithm specified separately.
+func signatureAlgorithm(sigOID, digestOID asn1.ObjectIdentifier) (x509.SignatureAlgorithm, error) {
+	hash, _ := digestHash(digestOID)
+	switch {
+	case sigOID.Equal(oidRSA):
+		switch hash {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA, nil
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case sigOID.Equal(oidECPublicKey):
+		switch hash {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1, nil
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	case sigOID.Equal(oidSHA1WithRSA):
+		return x509.SHA1WithRSA, nil
+	case sigOID.Equal(oidSHA256WithRSA):
+		return x509.SHA256WithRSA, nil
+	case sigOID.Equal(oidSHA384WithRSA):
+		return x509.SHA384WithRSA, nil
+	case sigOID.Equal(oidSHA512WithRSA):
+		return x509.SHA512WithRSA, nil
+	case sigOID.Equal(oidECDSAWithSHA256):
+		return x509.ECDSAWithSHA256, nil
+	case sigOID.Equal(oidECDSAWithSHA384):
+		return x509.ECDSAWithSHA384, nil
+	case sigOID.Equal(oidECDSAWithSHA512):
+		return x509.ECDSAWithSHA512, nil
+	case sigOID.Equal(oidEd25519):
+		return x509.PureEd25519, nil
+	}
+	return x509.UnknownSignatureAlgorithm, fmt.Errorf("%w: signature algorithm %s with digest %s", ErrUnsupported, sigOID, digestOID)
+}
+
+// Addresses returns the email addresses of a certificate, from the subject
+// alternative names, and from the email address attribute of the subject.
+func Addresses(cert *x509.Certificate) []smtp.Address {
+	var l []smtp.Address
+	add := func(s string) {
+		a, err := smtp.ParseAddress(s)
+		if err == nil && !hasAddress(l, a) {
+			l = append(l, a)
+		}
+	}
+	for _, s := range cert.EmailAddresses {
+		add(s)
+	}
+	for _, n := range cert.Subject.Names {
+		if s, ok := n.Value.(string); ok && n.Type.Equal(oidEmailAddress) {
+			add(s)
+		}
+	}
+	return l
+}
+
+func hasAddress(l []smtp.Address, addr smtp.Address) bool {
+	for _, a := range l {
+		if a.Domain == addr.Domain && strings.EqualFold(string(a.Localpart), string(addr.Localpart)) {
+			return true
+		}
+	}
+	return false
+}
+
+// HasAddress returns whether the certificate is for addr. Localparts are compared
+// case-insensitively.
+func HasAddress(cert *x509.Certificate, addr smtp.Address) bool {
+	return hasAddress(Addresses(cert), addr)
+}
+
+// Fingerprint returns the raw-url-base64-encoded SHA-256 hash of the public key
+// of the certificate, as also used for TLS public keys. The fingerprint stays the
+// same when a certificate is renewed for the same key.
+func Fingerprint(cert *x509.Certificate) string {
+	buf := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.RawURLEncoding.EncodeToString(buf[:])
+}
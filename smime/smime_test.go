@@ -0,0 +1,129 @@
+package smime
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/smtp"
+)
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+// makeCert returns a certificate for email protection, signed by parent, or
+// self-signed if parent is nil.
+func makeCert(t *testing.T, serial int64, email string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	tcheck(t, err, "generate key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "test " + email},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageEmailProtection},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if email != "" {
+		template.EmailAddresses = []string{email}
+	} else {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage = x509.KeyUsageCertSign
+	}
+	if parent == nil {
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(cryptorand.Reader, template, parent, &key.PublicKey, parentKey)
+	tcheck(t, err, "create certificate")
+	cert, err := x509.ParseCertificate(der)
+	tcheck(t, err, "parse certificate")
+	return cert, key
+}
+
+// sign returns a detached signature over data, with signed attributes.
+func sign(t *testing.T, data []byte, cert *x509.Certificate, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	digest := sha256.Sum256(data)
+	marshal := func(v any) []byte {
+		buf, err := asn1.Marshal(v)
+		tcheck(t, err, "marshal")
+		return buf
+	}
+	attrs := []attribute{
+		{oidContentType, []asn1.RawValue{{FullBytes: marshal(oidData)}}},
+		{oidMessageDigest, []asn1.RawValue{{FullBytes: marshal(digest[:])}}},
+	}
+	attrsBuf, err := asn1.MarshalWithParams(attrs, "set")
+	tcheck(t, err, "marshal attributes")
+	attrsDigest := sha256.Sum256(attrsBuf)
+	sig, err := key.Sign(cryptorand.Reader, attrsDigest[:], crypto.SHA256)
+	tcheck(t, err, "sign")
+
+	// Signed attributes are in the signer info with implicit [0] tag.
+	signedAttrs := asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrsBuf[2:]}
+	if attrsBuf[1]&0x80 != 0 {
+		t.Fatalf("long form length not handled in test")
+	}
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapContentInfo{EContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: cert.Raw},
+		SignerInfos: []signerInfo{{
+			Version:            1,
+			SID:                asn1.RawValue{FullBytes: marshal(issuerAndSerialNumber{asn1.RawValue{FullBytes: cert.RawIssuer}, cert.SerialNumber})},
+			DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			SignedAttrs:        signedAttrs,
+			SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256},
+			Signature:          sig,
+		}},
+	}
+	ci := contentInfo{oidSignedData, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: marshal(sd)}}
+	return marshal(ci)
+}
+
+func TestVerify(t *testing.T) {
+	caCert, caKey := makeCert(t, 1, "", nil, nil)
+	cert, key := makeCert(t, 2, "mjl@mox.example", caCert, caKey)
+	roots := x509.NewCertPool()
+	roots.AddCert(caCert)
+
+	data := []byte("Content-Type: text/plain\r\n\r\nhi\r\n")
+	sig := sign(t, data, cert, key)
+
+	r, err := Verify(data, sig, roots, time.Now())
+	tcheck(t, err, "verify")
+	if r.Signer.SerialNumber.Int64() != 2 || len(r.Chain) != 2 {
+		t.Fatalf("unexpected result %#v", r)
+	}
+	if a, err := smtp.ParseAddress("MJL@mox.example"); err != nil || !HasAddress(r.Signer, a) {
+		t.Fatalf("certificate does not have address, err %v", err)
+	}
+
+	test := func(data, sig []byte, roots *x509.CertPool, now time.Time, expErr error) {
+		t.Helper()
+		_, err := Verify(data, sig, roots, now)
+		if err == nil || !errors.Is(err, expErr) {
+			t.Fatalf("got err %v, expected %v", err, expErr)
+		}
+	}
+	test([]byte("other"), sig, roots, time.Now(), ErrSignature)
+	test(data, sig, nil, time.Now(), ErrCertificate)
+	test(data, sig, roots, time.Now().Add(2*time.Hour), ErrCertificate)
+	test(data, []byte("bogus"), roots, time.Now(), ErrSyntax)
+}
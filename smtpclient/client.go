@@ -124,6 +124,7 @@ type Client struct {
 	w                       *bufio.Writer
 	tr                      *moxio.TraceReader // Kept for changing trace levels between cmd/auth/data.
 	tw                      *moxio.TraceWriter
+	transcript              *transcript // If not nil, protocol exchange is also written to transcript.
 	log                     mlog.Log
 	lastlog                 time.Time // For adding delta timestamps between log lines.
 	cmds                    []string  // Last or active command, for generating errors and metrics.
@@ -144,6 +145,8 @@ type Client struct {
 	extSMTPUTF8           bool              // Remote server supports SMTPUTF8 extension.
 	extAuthMechanisms     []string          // Supported authentication mechanisms.
 	extRequireTLS         bool              // Remote supports REQUIRETLS extension.
+	extMTPriority         bool              // Remote supports MT-PRIORITY extension.
+	mtPriority            int               // For next transaction, see SetMTPriority.
 	ExtLimits             map[string]string // For LIMITS extension, only if present and valid, with uppercase keys.
 	ExtLimitMailMax       int               // Max "MAIL" commands in a connection, if > 0.
 	ExtLimitRcptMax       int               // Max "RCPT" commands in a transaction, if > 0.
@@ -235,6 +238,10 @@ type Opts struct {
 	// tracked.
 	RecipientDomainResult *tlsrpt.Result // MTA-STS or no policy.
 	HostResult            *tlsrpt.Result // DANE or no policy.
+
+	// If set, a transcript of the SMTP protocol exchange is written, without
+	// authentication exchanges and message data.
+	Transcript io.Writer
 }
 
 // New initializes an SMTP session on the given connection, returning a client that
@@ -291,6 +298,9 @@ func New(ctx context.Context, elog *slog.Logger, conn net.Conn, tlsMode TLSMode,
 		recipientDomainResult: ensureResult(opts.RecipientDomainResult),
 		hostResult:            ensureResult(opts.HostResult),
 	}
+	if opts.Transcript != nil {
+		c.transcript = &transcript{w: opts.Transcript}
+	}
 	c.log = mlog.New("smtpclient", elog).WithFunc(func() []slog.Attr {
 		now := time.Now()
 		l := []slog.Attr{
@@ -324,11 +334,11 @@ func New(ctx context.Context, elog *slog.Logger, conn net.Conn, tlsMode TLSMode,
 	// We don't wrap reads in a timeoutReader for fear of an optional TLS wrapper doing
 	// reads without the client asking for it. Such reads could result in a timeout
 	// error.
-	c.tr = moxio.NewTraceReader(c.log, "RS: ", c.conn)
+	c.tr = moxio.NewTraceReader(c.log, "RS: ", c.transcriptReader(c.conn))
 	c.r = bufio.NewReader(c.tr)
 	// We use a single write timeout of 30 seconds.
 	// todo future: use different timeouts ../rfc/5321:3610
-	c.tw = moxio.NewTraceWriter(c.log, "LC: ", timeoutWriter{c.conn, 30 * time.Second, c.log})
+	c.tw = moxio.NewTraceWriter(c.log, "LC: ", c.transcriptWriter(timeoutWriter{c.conn, 30 * time.Second, c.log}))
 	c.w = bufio.NewWriter(c.tw)
 
 	if err := c.hello(ctx, tlsMode, ehloHostname, opts.Auth); err != nil {
@@ -508,11 +518,33 @@ func (c *Client) xtrace(level slog.Level) func() {
 	c.xflush()
 	c.tr.SetTrace(level)
 	c.tw.SetTrace(level)
+	if c.transcript != nil {
+		c.transcript.setLevel(level)
+	}
 	return func() {
 		c.xflush()
 		c.tr.SetTrace(mlog.LevelTrace)
 		c.tw.SetTrace(mlog.LevelTrace)
+		if c.transcript != nil {
+			c.transcript.setLevel(mlog.LevelTrace)
+		}
+	}
+}
+
+// transcriptReader returns r wrapped to also write to the transcript, if any.
+func (c *Client) transcriptReader(r io.Reader) io.Reader {
+	if c.transcript == nil {
+		return r
+	}
+	return transcriptReader{c.transcript, r}
+}
+
+// transcriptWriter returns w wrapped to also write to the transcript, if any.
+func (c *Client) transcriptWriter(w io.Writer) io.Writer {
+	if c.transcript == nil {
+		return w
 	}
+	return transcriptWriter{c.transcript, w}
 }
 
 func (c *Client) xwritelinef(format string, args ...any) {
@@ -762,6 +794,9 @@ func (c *Client) hello(ctx context.Context, tlsMode TLSMode, ehloHostname dns.Do
 					}
 				} else if strings.HasPrefix(s, "AUTH ") {
 					c.extAuthMechanisms = strings.Split(s[len("AUTH "):], " ")
+				} else if s == "MT-PRIORITY" || strings.HasPrefix(s, "MT-PRIORITY ") {
+					// Optionally with the name of the priority assignment policy. RFC 6710.
+					c.extMTPriority = true
 				} else if strings.HasPrefix(s, "LIMITS ") {
 					c.ExtLimits, c.ExtLimitMailMax, c.ExtLimitRcptMax, c.ExtLimitRcptDomainMax = parseLimits([]byte(s[len("LIMITS"):]))
 				}
@@ -823,8 +858,8 @@ func (c *Client) hello(ctx context.Context, tlsMode TLSMode, ehloHostname dns.Do
 		}
 		c.firstReadAfterHandshake = true
 		cancel()
-		c.tr = moxio.NewTraceReader(c.log, "RS: ", c.conn)
-		c.tw = moxio.NewTraceWriter(c.log, "LC: ", c.conn) // No need to wrap in timeoutWriter, it would just set the timeout on the underlying connection, which is still active.
+		c.tr = moxio.NewTraceReader(c.log, "RS: ", c.transcriptReader(c.conn))
+		c.tw = moxio.NewTraceWriter(c.log, "LC: ", c.transcriptWriter(c.conn)) // No need to wrap in timeoutWriter, it would just set the timeout on the underlying connection, which is still active.
 		c.r = bufio.NewReader(c.tr)
 		c.w = bufio.NewWriter(c.tw)
 
@@ -1081,6 +1116,20 @@ func (c *Client) SupportsRequireTLS() bool {
 	return c.extRequireTLS
 }
 
+// SupportsMTPriority returns whether the SMTP server supports the MT-PRIORITY
+// extension.
+func (c *Client) SupportsMTPriority() bool {
+	return c.extMTPriority
+}
+
+// SetMTPriority sets the priority, from -9 to 9, for the next message delivery.
+// It is sent as MT-PRIORITY parameter in the MAIL FROM command if non-zero and the
+// remote server supports the MT-PRIORITY extension, and ignored otherwise. The
+// priority only applies to a single delivery.
+func (c *Client) SetMTPriority(priority int) {
+	c.mtPriority = priority
+}
+
 // TLSConnectionState returns TLS details if TLS is enabled, and nil otherwise.
 func (c *Client) TLSConnectionState() *tls.ConnectionState {
 	if tlsConn, ok := c.conn.(*tls.Conn); ok {
@@ -1107,7 +1156,8 @@ func (c *Client) TLSConnectionState() *tls.ConnectionState {
 // extension, or delivery will fail.
 //
 // Deliver uses the following SMTP extensions if the remote server supports them:
-// 8BITMIME, SMTPUTF8, SIZE, PIPELINING, ENHANCEDSTATUSCODES, STARTTLS.
+// 8BITMIME, SMTPUTF8, SIZE, PIPELINING, ENHANCEDSTATUSCODES, STARTTLS,
+// MT-PRIORITY (see SetMTPriority).
 //
 // Returned errors can be of type Error, one of the Err-variables in this package
 // or other underlying errors, e.g. for i/o. Use errors.Is to check.
@@ -1185,12 +1235,17 @@ func (c *Client) DeliverMultiple(ctx context.Context, mailFrom string, rcptTo []
 		// ../rfc/8689:155
 		requiretlsArg = " REQUIRETLS"
 	}
+	var mtPriorityArg string
+	if c.extMTPriority && c.mtPriority != 0 {
+		mtPriorityArg = fmt.Sprintf(" MT-PRIORITY=%d", c.mtPriority)
+	}
+	c.mtPriority = 0
 
 	// Transaction overview: ../rfc/5321:1015
 	// MAIL FROM: ../rfc/5321:1879
 	// RCPT TO: ../rfc/5321:1916
 	// DATA: ../rfc/5321:1992
-	lineMailFrom := fmt.Sprintf("MAIL FROM:<%s>%s%s%s%s", mailFrom, mailSize, bodyType, smtputf8Arg, requiretlsArg)
+	lineMailFrom := fmt.Sprintf("MAIL FROM:<%s>%s%s%s%s%s", mailFrom, mailSize, bodyType, smtputf8Arg, requiretlsArg, mtPriorityArg)
 
 	// We are going into a transaction. We'll clear this when done.
 	c.needRset = true
@@ -1406,6 +1461,16 @@ func (c *Client) Reset() (rerr error) {
 	return
 }
 
+// SetTranscript changes the writer for the transcript of the protocol exchange,
+// e.g. when the connection is reused for another delivery. It has no effect if the
+// client was created without Opts.Transcript.
+func (c *Client) SetTranscript(w io.Writer) {
+	if c.transcript != nil {
+		c.transcript.w = w
+		c.transcript.line = [2]bool{}
+	}
+}
+
 // Botched returns whether this connection is botched, e.g. a protocol error
 // occurred and the connection is in unknown state, and cannot be used for message
 // delivery.
@@ -48,6 +48,7 @@ func TestClient(t *testing.T) {
 		eightbitmime bool
 		smtputf8     bool
 		requiretls   bool
+		mtpriority   bool
 		ehlo         bool
 		auths        []string // Allowed mechanisms.
 
@@ -61,6 +62,7 @@ func TestClient(t *testing.T) {
 		need8bitmime    bool
 		needsmtputf8    bool
 		needsrequiretls bool
+		priority        int
 		recipients      []string   // If nil, mjl@mox.example is used.
 		resps           []Response // Checked only if non-nil.
 	}
@@ -168,6 +170,9 @@ func TestClient(t *testing.T) {
 				if opts.requiretls && haveTLS {
 					writeline("250-REQUIRETLS")
 				}
+				if opts.mtpriority {
+					writeline("250-MT-PRIORITY MIXER")
+				}
 				if opts.auths != nil {
 					writeline("250-AUTH " + strings.Join(opts.auths, " "))
 				}
@@ -267,7 +272,11 @@ func TestClient(t *testing.T) {
 			}
 
 			if expClientErr == nil && !opts.nodeliver {
-				readline("MAIL FROM:")
+				mailFrom := readline("MAIL FROM:")
+				expPriority := opts.mtpriority && opts.priority != 0
+				if strings.Contains(mailFrom, fmt.Sprintf(" MT-PRIORITY=%d", opts.priority)) != expPriority {
+					fail("mail from %q, expected mt-priority %v", mailFrom, expPriority)
+				}
 				writeline("250 ok")
 				n := len(opts.recipients)
 				if n == 0 {
@@ -291,7 +300,11 @@ func TestClient(t *testing.T) {
 					readline("RSET")
 					writeline("250 ok")
 
-					readline("MAIL FROM:")
+					// Priority only applies to a single delivery.
+					mailFrom = readline("MAIL FROM:")
+					if strings.Contains(mailFrom, "MT-PRIORITY") {
+						fail("mail from %q, unexpected mt-priority", mailFrom)
+					}
 					writeline("250 ok")
 					for i := range n {
 						readline("RCPT TO:")
@@ -340,6 +353,7 @@ func TestClient(t *testing.T) {
 			if len(rcptTo) == 0 {
 				rcptTo = []string{"mjl@mox.example"}
 			}
+			client.SetMTPriority(opts.priority)
 			resps, err := client.DeliverMultiple(ctx, "postmaster@mox.example", rcptTo, int64(len(msg)), strings.NewReader(msg), opts.need8bitmime, opts.needsmtputf8, opts.needsrequiretls)
 			if (err == nil) != (expDeliverErr == nil) || err != nil && !errors.Is(err, expDeliverErr) && !reflect.DeepEqual(err, expDeliverErr) {
 				fail("first deliver: got err %#v (%s), expected %#v (%s)", err, err, expDeliverErr, expDeliverErr)
@@ -406,6 +420,8 @@ test
 	test(msg, options{}, nil, nil, nil, nil)
 	test(msg, allopts, nil, nil, nil, nil)
 	test(msg, options{ehlo: true, eightbitmime: true}, nil, nil, nil, nil)
+	test(msg, options{ehlo: true, mtpriority: true, priority: -3}, nil, nil, nil, nil)
+	test(msg, options{ehlo: true, mtpriority: false, priority: 5}, nil, nil, nil, nil) // Not sent if unsupported.
 	test(msg, options{ehlo: true, eightbitmime: false, need8bitmime: true, nodeliver: true}, nil, nil, Err8bitmimeUnsupported, nil)
 	test(msg, options{ehlo: true, smtputf8: false, needsmtputf8: true, nodeliver: true}, nil, nil, ErrSMTPUTF8Unsupported, nil)
 
@@ -933,6 +949,24 @@ func TestLimits(t *testing.T) {
 	check(" DUP=1 DUP=2", nil, 0, 0, 0)
 }
 
+func TestTranscript(t *testing.T) {
+	var b strings.Builder
+	tr := &transcript{w: &b}
+	tr.add(true, []byte("220 mox.example\r\n"))
+	tr.add(false, []byte("AUTH PLAIN\r\n"))
+	tr.add(true, []byte("334 \r"))
+	tr.add(true, []byte("\n"))
+	tr.setLevel(mlog.LevelTraceauth)
+	tr.add(false, []byte("AHVzZXIAcGFzc3dvcmQ=\r\n"))
+	tr.add(false, []byte("more\r\n"))
+	tr.setLevel(mlog.LevelTrace)
+	tr.add(true, []byte("235 2.7.0 ok\r\n250-multi\r\n250 line\r\n"))
+	exp := "S: 220 mox.example\nC: AUTH PLAIN\nS: 334 \nC: (omitted)\nS: 235 2.7.0 ok\nS: 250-multi\nS: 250 line\n"
+	if got := b.String(); got != exp {
+		t.Fatalf("got transcript %q, expected %q", got, exp)
+	}
+}
+
 // Just a cert that appears valid. SMTP client will not verify anything about it
 // (that is opportunistic TLS for you, "better some than none"). Let's enjoy this
 // one moment where it makes life easier.
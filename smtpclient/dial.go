@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"slices"
+	"sort"
 	"time"
 
 	"github.com/mjl-/mox/dns"
@@ -37,6 +39,25 @@ type Dialer interface {
 	DialContext(ctx context.Context, network, addr string) (c net.Conn, err error)
 }
 
+// DefaultHappyEyeballsDelay is the delay before starting a connection attempt to
+// the next IP while earlier attempts are still in progress, as recommended by RFC
+// 8305.
+const DefaultHappyEyeballsDelay = 250 * time.Millisecond
+
+// DialPolicy influences how Dial connects to the IPs of a host.
+type DialPolicy struct {
+	// Address family to try first for the first connection to a host: "ipv6",
+	// "ipv4", or empty to keep the order of the IPs. For later connections, the order
+	// from GatherIPs, based on earlier attempts, is kept.
+	PreferIPFamily string
+
+	// If > 0, connection attempts are raced ("happy eyeballs"): When a connection
+	// attempt hasn't completed after this delay, the next IP is dialed in parallel,
+	// alternating between address families. The first connection that succeeds is
+	// used. If 0, IPs are dialed one at a time.
+	HappyEyeballsDelay time.Duration
+}
+
 // Dial connects to host by dialing ips, taking previous attempts in dialedIPs into
 // accounts (for greylisting, blocklisting and ipv4/ipv6).
 //
@@ -46,21 +67,55 @@ type Dialer interface {
 // The second attempt for an address family we prefer the same IP as earlier, to
 // increase our chances if remote is doing greylisting.
 //
+// For the first attempt, the address family from policy is preferred. With
+// happy eyeballs enabled in policy, IPs of both address families are dialed
+// interleaved, with later attempts started while earlier attempts are still in
+// progress.
+//
 // Dial updates dialedIPs, callers may want to save it so it can be taken into
 // account for future delivery attempts.
 //
 // The first matching protocol family from localIPs is set for the local side
 // of the TCP connection.
-func Dial(ctx context.Context, elog *slog.Logger, dialer Dialer, host dns.IPDomain, ips []net.IP, port int, dialedIPs map[string][]net.IP, localIPs []net.IP) (conn net.Conn, ip net.IP, rerr error) {
+func Dial(ctx context.Context, elog *slog.Logger, dialer Dialer, host dns.IPDomain, ips []net.IP, port int, dialedIPs map[string][]net.IP, localIPs []net.IP, policy DialPolicy) (conn net.Conn, ip net.IP, rerr error) {
 	log := mlog.New("smtpclient", elog)
 	timeout := 30 * time.Second
 	if deadline, ok := ctx.Deadline(); ok && len(ips) > 0 {
 		timeout = time.Until(deadline) / time.Duration(len(ips))
 	}
 
-	var lastErr error
-	var lastIP net.IP
-	for _, ip := range ips {
+	if policy.PreferIPFamily != "" && len(dialedIPs[host.String()]) == 0 {
+		prefer4 := policy.PreferIPFamily == "ipv4"
+		preferred := func(ip net.IP) bool {
+			return (ip.To4() != nil) == prefer4
+		}
+		ips = slices.Clone(ips)
+		sort.SliceStable(ips, func(i, j int) bool {
+			return preferred(ips[i]) && !preferred(ips[j])
+		})
+	}
+	if policy.HappyEyeballsDelay > 0 {
+		ips = interleaveIPFamilies(ips)
+	}
+	log.Debug("dialing ips", slog.Any("ips", ips), slog.Duration("happyeyeballsdelay", policy.HappyEyeballsDelay))
+
+	type result struct {
+		conn  net.Conn
+		ip    net.IP
+		laddr net.Addr
+		err   error
+	}
+
+	// With happy eyeballs, the context is canceled once we have a connection, aborting
+	// other pending attempts.
+	dialctx, dialcancel := context.WithCancel(ctx)
+	defer dialcancel()
+	results := make(chan result, len(ips))
+	var next, pending int
+	start := func() {
+		ip := ips[next]
+		next++
+		pending++
 		addr := net.JoinHostPort(ip.String(), fmt.Sprintf("%d", port))
 		log.Debug("dialing host", slog.String("addr", addr))
 		var laddr net.Addr
@@ -72,23 +127,113 @@ func Dial(ctx context.Context, elog *slog.Logger, dialer Dialer, host dns.IPDoma
 				break
 			}
 		}
-		conn, err := dial(ctx, dialer, timeout, addr, laddr)
-		if err == nil {
-			log.Debug("connected to host",
+		if policy.HappyEyeballsDelay <= 0 {
+			conn, err := dial(dialctx, dialer, timeout, addr, laddr)
+			results <- result{conn, ip, laddr, err}
+			return
+		}
+		go func() {
+			conn, err := dial(dialctx, dialer, timeout, addr, laddr)
+			results <- result{conn, ip, laddr, err}
+		}()
+	}
+
+	var delay <-chan time.Time
+	startNext := func() {
+		if next >= len(ips) {
+			return
+		}
+		start()
+		if policy.HappyEyeballsDelay > 0 {
+			delay = time.After(policy.HappyEyeballsDelay)
+		}
+	}
+
+	var lastErr error
+	var lastIP net.IP
+	startNext()
+	for pending > 0 {
+		var r result
+		select {
+		case r = <-results:
+		case <-delay:
+			delay = nil
+			startNext()
+			continue
+		}
+		pending--
+		addr := net.JoinHostPort(r.ip.String(), fmt.Sprintf("%d", port))
+		if r.err != nil {
+			log.Debugx("connection attempt", r.err,
 				slog.Any("host", host),
 				slog.String("addr", addr),
-				slog.Any("laddr", laddr))
-			name := host.String()
-			dialedIPs[name] = append(dialedIPs[name], ip)
-			return conn, ip, nil
+				slog.Any("laddr", r.laddr))
+			lastErr = r.err
+			lastIP = r.ip
+			startNext()
+			continue
 		}
-		log.Debugx("connection attempt", err,
+
+		if pending > 0 {
+			// Cancel other attempts, and close connections of attempts that still succeed.
+			dialcancel()
+			go func(n int) {
+				for range n {
+					if r := <-results; r.conn != nil {
+						r.conn.Close()
+					}
+				}
+			}(pending)
+		}
+		family := "ipv6"
+		if r.ip.To4() != nil {
+			family = "ipv4"
+		}
+		attrs := []slog.Attr{
 			slog.Any("host", host),
 			slog.String("addr", addr),
-			slog.Any("laddr", laddr))
-		lastErr = err
-		lastIP = ip
+			slog.Any("laddr", r.laddr),
+			slog.String("family", family),
+		}
+		if !r.ip.Equal(ips[0]) {
+			log.Info("connected to host after falling back from first ip", append(attrs, slog.Any("firstip", ips[0]))...)
+		} else {
+			log.Debug("connected to host", attrs...)
+		}
+		name := host.String()
+		dialedIPs[name] = append(dialedIPs[name], r.ip)
+		return r.conn, r.ip, nil
 	}
 	// todo: possibly return all errors joined?
 	return nil, lastIP, lastErr
 }
+
+// interleaveIPFamilies returns ips with IPv4 and IPv6 addresses alternating,
+// starting with the address family of the first IP, keeping the order within
+// each address family.
+func interleaveIPFamilies(ips []net.IP) []net.IP {
+	if len(ips) < 2 {
+		return ips
+	}
+	first4 := ips[0].To4() != nil
+	var a, b []net.IP
+	for _, ip := range ips {
+		if (ip.To4() != nil) == first4 {
+			a = append(a, ip)
+		} else {
+			b = append(b, ip)
+		}
+	}
+	r := make([]net.IP, 0, len(ips))
+	for len(a) > 0 || len(b) > 0 {
+		if len(a) > 0 {
+			r = append(r, a[0])
+			a = a[1:]
+		}
+		if len(b) > 0 {
+			r = append(r, b[0])
+			b = b[1:]
+		}
+	}
+	return r
+}
@@ -2,8 +2,12 @@ package smtpclient
 
 import (
 	"context"
+	"errors"
 	"net"
 	"reflect"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -41,7 +45,7 @@ func TestDialHost(t *testing.T) {
 	if err != nil || !reflect.DeepEqual(ips, []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("2001:db8::1")}) || !dualstack {
 		t.Fatalf("expected err nil, address 10.0.0.1,2001:db8::1, dualstack true, got %v %v %v", err, ips, dualstack)
 	}
-	_, ip, err := Dial(ctxbg, log.Logger, nil, ipdomain("dualstack.example"), ips, 25, dialedIPs, nil)
+	_, ip, err := Dial(ctxbg, log.Logger, nil, ipdomain("dualstack.example"), ips, 25, dialedIPs, nil, DialPolicy{})
 	if err != nil || ip.String() != "10.0.0.1" {
 		t.Fatalf("expected err nil, address 10.0.0.1, dualstack true, got %v %v %v", err, ip, dualstack)
 	}
@@ -50,8 +54,89 @@ func TestDialHost(t *testing.T) {
 	if err != nil || !reflect.DeepEqual(ips, []net.IP{net.ParseIP("2001:db8::1"), net.ParseIP("10.0.0.1")}) || !dualstack {
 		t.Fatalf("expected err nil, address 2001:db8::1,10.0.0.1, dualstack true, got %v %v %v", err, ips, dualstack)
 	}
-	_, ip, err = Dial(ctxbg, log.Logger, nil, ipdomain("dualstack.example"), ips, 25, dialedIPs, nil)
+	_, ip, err = Dial(ctxbg, log.Logger, nil, ipdomain("dualstack.example"), ips, 25, dialedIPs, nil, DialPolicy{})
 	if err != nil || ip.String() != "2001:db8::1" {
 		t.Fatalf("expected err nil, address 2001:db8::1, dualstack true, got %v %v %v", err, ip, dualstack)
 	}
 }
+
+func TestDialHappyEyeballs(t *testing.T) {
+	ctxbg := context.Background()
+	log := mlog.New("smtpclient", nil)
+
+	ips := []net.IP{net.ParseIP("10.0.0.1"), net.ParseIP("10.0.0.2"), net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")}
+	host := dns.IPDomain{Domain: dns.Domain{ASCII: "dualstack.example"}}
+
+	// IPv6 connections hang until canceled, IPv4 connections to 10.0.0.2 succeed.
+	var mu sync.Mutex
+	var dialed []string
+	canceled := make(chan string, len(ips))
+	DialHook = func(ctx context.Context, dialer Dialer, timeout time.Duration, addr string, laddr net.Addr) (net.Conn, error) {
+		mu.Lock()
+		dialed = append(dialed, addr)
+		mu.Unlock()
+		if strings.HasPrefix(addr, "[") {
+			<-ctx.Done()
+			canceled <- addr
+			return nil, ctx.Err()
+		} else if addr == "10.0.0.2:25" {
+			return nil, nil // No error, nil connection isn't used.
+		}
+		return nil, errors.New("connection refused")
+	}
+	defer func() {
+		DialHook = nil
+	}()
+
+	policy := DialPolicy{PreferIPFamily: "ipv6", HappyEyeballsDelay: 10 * time.Millisecond}
+	dialedIPs := map[string][]net.IP{}
+	_, ip, err := Dial(ctxbg, log.Logger, nil, host, ips, 25, dialedIPs, nil, policy)
+	if err != nil || ip.String() != "10.0.0.2" {
+		t.Fatalf("got err %v, ip %v, expected nil error and 10.0.0.2", err, ip)
+	}
+	// Attempts alternate between families, starting with the preferred family.
+	mu.Lock()
+	exp := []string{"[2001:db8::1]:25", "10.0.0.1:25", "[2001:db8::2]:25", "10.0.0.2:25"}
+	if !reflect.DeepEqual(dialed, exp) {
+		t.Fatalf("dialed %v, expected %v", dialed, exp)
+	}
+	mu.Unlock()
+	// Pending IPv6 attempts are canceled.
+	for range 2 {
+		select {
+		case <-canceled:
+		case <-time.After(time.Second):
+			t.Fatalf("pending connection attempt not canceled")
+		}
+	}
+	if !reflect.DeepEqual(dialedIPs[host.String()], []net.IP{ip}) {
+		t.Fatalf("dialedIPs %v, expected %v", dialedIPs, ip)
+	}
+
+	// Without happy eyeballs, IPs are dialed one at a time, not interleaved. The
+	// preference is only used for the first connection to a host.
+	DialHook = func(ctx context.Context, dialer Dialer, timeout time.Duration, addr string, laddr net.Addr) (net.Conn, error) {
+		dialed = append(dialed, addr)
+		return nil, errors.New("connection refused")
+	}
+	dialed = nil
+	policy = DialPolicy{PreferIPFamily: "ipv4"}
+	_, ip, err = Dial(ctxbg, log.Logger, nil, host, slices.Concat(ips[2:], ips[:2]), 25, map[string][]net.IP{}, nil, policy)
+	if err == nil || ip.String() != "2001:db8::2" {
+		t.Fatalf("got err %v, ip %v, expected error for 2001:db8::2", err, ip)
+	}
+	exp = []string{"10.0.0.1:25", "10.0.0.2:25", "[2001:db8::1]:25", "[2001:db8::2]:25"}
+	if !reflect.DeepEqual(dialed, exp) {
+		t.Fatalf("dialed %v, expected %v", dialed, exp)
+	}
+
+	dialed = nil
+	_, _, err = Dial(ctxbg, log.Logger, nil, host, slices.Concat(ips[2:], ips[:2]), 25, map[string][]net.IP{host.String(): {ips[0]}}, nil, policy)
+	if err == nil {
+		t.Fatalf("got nil error, expected error")
+	}
+	exp = []string{"[2001:db8::1]:25", "[2001:db8::2]:25", "10.0.0.1:25", "10.0.0.2:25"}
+	if !reflect.DeepEqual(dialed, exp) {
+		t.Fatalf("dialed %v, expected %v", dialed, exp)
+	}
+}
@@ -33,7 +33,8 @@ var (
 // present but indicates the domain does not accept email, ErrNoMail is returned.
 // If valid MX records were found, the MX target hosts are returned.
 //
-// haveMX indicates if an MX record was found.
+// haveMX indicates if an MX record was found. If so, hostPrefs has the MX
+// preference for each host, with hosts ordered by preference.
 //
 // origNextHopAuthentic indicates if the DNS record for the initial domain name was
 // DNSSEC secure (CNAME, MX).
@@ -46,14 +47,14 @@ var (
 // were found, both the original and expanded next-hops must be authentic for DANE
 // to be option. For a non-IP with no MX records found, the authentic result can
 // be used to decide which of the names to use as TLSA base domain.
-func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, origNextHop dns.IPDomain) (haveMX, origNextHopAuthentic, expandedNextHopAuthentic bool, expandedNextHop dns.Domain, hosts []dns.IPDomain, permanent bool, err error) {
+func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, origNextHop dns.IPDomain) (haveMX, origNextHopAuthentic, expandedNextHopAuthentic bool, expandedNextHop dns.Domain, hosts []dns.IPDomain, hostPrefs []uint16, permanent bool, err error) {
 	// ../rfc/5321:3824
 
 	log := mlog.New("smtpclient", elog)
 
 	// IP addresses are dialed directly, and don't have TLSA records.
 	if len(origNextHop.IP) > 0 {
-		return false, false, false, expandedNextHop, []dns.IPDomain{origNextHop}, false, nil
+		return false, false, false, expandedNextHop, []dns.IPDomain{origNextHop}, nil, false, nil
 	}
 
 	// We start out assuming the result is authentic. Updated with each lookup.
@@ -70,7 +71,7 @@ func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Res
 		if domainsSeen[expandedNextHop.ASCII] {
 			// todo: only mark as permanent failure if TTLs for all records are beyond latest possibly delivery retry we would do.
 			err := fmt.Errorf("%w: recipient domain %s: already saw %s", errCNAMELoop, rcptDomain, expandedNextHop)
-			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, false, err
+			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, false, err
 		}
 		domainsSeen[expandedNextHop.ASCII] = true
 
@@ -83,7 +84,7 @@ func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Res
 			// to the internet.
 			// todo: only mark as permanent failure if TTLs for all records are beyond latest possibly delivery retry we would do.
 			err := fmt.Errorf("%w: recipient domain %s, last resolved domain %s", errCNAMELimit, rcptDomain, expandedNextHop)
-			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, false, err
+			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, false, err
 		}
 
 		// Do explicit CNAME lookup. Go's LookupMX also resolves CNAMEs, but we want to
@@ -100,14 +101,14 @@ func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Res
 		expandedNextHopAuthentic = expandedNextHopAuthentic && cnameResult.Authentic
 		if err != nil && !dns.IsNotFound(err) {
 			err = fmt.Errorf("%w: cname lookup for %s: %v", errDNS, expandedNextHop, err)
-			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, false, err
+			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, false, err
 		}
 		if err == nil && cname != expandedNextHop.ASCII+"." {
 			d, err := dns.ParseDomain(strings.TrimSuffix(cname, "."))
 			if err != nil {
 				// todo: only mark as permanent failure if TTLs for all records are beyond latest possibly delivery retry we would do.
 				err = fmt.Errorf("%w: parsing cname domain %s: %v", errDNS, expandedNextHop, err)
-				return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, false, err
+				return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, false, err
 			}
 			expandedNextHop = d
 			// Start again with new domain.
@@ -129,12 +130,12 @@ func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Res
 		if err != nil && len(mxl) == 0 {
 			if !dns.IsNotFound(err) {
 				err = fmt.Errorf("%w: mx lookup for %s: %v", errDNS, expandedNextHop, err)
-				return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, false, err
+				return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, false, err
 			}
 
 			// No MX record, attempt delivery directly to host. ../rfc/5321:3842
 			hosts = []dns.IPDomain{{Domain: expandedNextHop}}
-			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, false, nil
+			return false, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, nil, false, nil
 		} else if err != nil {
 			log.Infox("mx record has some invalid records, keeping only the valid mx records", err)
 		}
@@ -145,7 +146,7 @@ func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Res
 			// receptive MX record before our final delivery attempt. But it's clearly the
 			// explicit desire not to be bothered with email delivery attempts, so mark failure
 			// as permanent.
-			return true, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, true, errNoMail
+			return true, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, true, errNoMail
 		}
 
 		// The Go resolver already sorts by preference, randomizing records of same
@@ -156,14 +157,15 @@ func GatherDestinations(ctx context.Context, elog *slog.Logger, resolver dns.Res
 			if err != nil {
 				// note: should not happen because Go resolver already filters these out.
 				err = fmt.Errorf("%w: invalid host name in mx record %q: %v", errDNS, mx.Host, err)
-				return true, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, true, err
+				return true, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, nil, nil, true, err
 			}
 			hosts = append(hosts, dns.IPDomain{Domain: host})
+			hostPrefs = append(hostPrefs, mx.Pref)
 		}
 		if len(hosts) > 0 {
 			err = nil
 		}
-		return true, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, false, err
+		return true, origNextHopAuthentic, expandedNextHopAuthentic, expandedNextHop, hosts, hostPrefs, false, err
 	}
 }
 
@@ -0,0 +1,93 @@
+package smtpclient
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+
+	"github.com/mjl-/mox/mlog"
+)
+
+// transcript writes the protocol exchange to a writer, with lines prefixed with
+// "C: " for data from the client and "S: " for data from the server.
+// Authentication exchanges and message data are not included, a single line
+// mentioning the omission is written instead.
+type transcript struct {
+	w       io.Writer
+	omit    bool // Set during authentication and message data.
+	omitted bool // Whether omission was already written, for the current omit period.
+	line    [2]bool
+}
+
+// add writes buf as sent (fromServer false) or received (fromServer true) to the
+// transcript.
+func (t *transcript) add(fromServer bool, buf []byte) {
+	prefix := "C: "
+	var i int
+	if fromServer {
+		prefix = "S: "
+		i = 1
+	}
+	if t.omit {
+		if !t.omitted {
+			t.omitted = true
+			if t.line[0] || t.line[1] {
+				t.w.Write([]byte("\n"))
+				t.line = [2]bool{}
+			}
+			t.w.Write([]byte(prefix + "(omitted)\n"))
+		}
+		return
+	}
+	// CRs are dropped, the CRLF line ending could be split over writes.
+	buf = bytes.ReplaceAll(buf, []byte("\r"), nil)
+	for len(buf) > 0 {
+		if !t.line[i] {
+			t.w.Write([]byte(prefix))
+			t.line[i] = true
+		}
+		n := bytes.IndexByte(buf, '\n')
+		if n < 0 {
+			t.w.Write(buf)
+			return
+		}
+		t.w.Write(buf[:n+1])
+		t.line[i] = false
+		buf = buf[n+1:]
+	}
+}
+
+// setLevel starts or stops omitting data, based on the trace level, which is
+// raised for authentication and message data.
+func (t *transcript) setLevel(level slog.Level) {
+	t.omit = level != mlog.LevelTrace
+	if !t.omit {
+		t.omitted = false
+	}
+}
+
+type transcriptReader struct {
+	t *transcript
+	r io.Reader
+}
+
+func (r transcriptReader) Read(buf []byte) (int, error) {
+	n, err := r.r.Read(buf)
+	if n > 0 {
+		r.t.add(true, buf[:n])
+	}
+	return n, err
+}
+
+type transcriptWriter struct {
+	t *transcript
+	w io.Writer
+}
+
+func (w transcriptWriter) Write(buf []byte) (int, error) {
+	n, err := w.w.Write(buf)
+	if n > 0 {
+		w.t.add(false, buf[:n])
+	}
+	return n, err
+}
@@ -6,7 +6,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/mjl-/bstore"
+
 	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlist"
+	"github.com/mjl-/mox/queue"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/smtpclient"
 	"github.com/mjl-/mox/store"
@@ -286,3 +290,180 @@ test email
 		ts.smtpErr(err, &smtpclient.Error{Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
 	})
 }
+
+// Distribution list with list headers and member delivery preferences.
+func TestAliasDeliverList(t *testing.T) {
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // To get passed junk filter.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	var msg = strings.ReplaceAll(`From: <other@example.org>
+To: <list@mox.example>
+Subject: test
+
+test email
+`, "\n", "\r\n")
+
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "other@example.org"
+		rcptTo := "list@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+		ts.smtpErr(err, nil)
+
+		// Only delivered for mjl@, to its preferred mailbox. Not for móx@, it has delivery disabled.
+		ts.checkCount("Lists", 1)
+		ts.checkCount("Inbox", 0)
+	})
+
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).Get()
+	tcheck(t, err, "get message")
+	if !strings.Contains(string(m.MsgPrefix), "List-Id: <list.mox.example>\r\nList-Post: <mailto:list@mox.example>\r\n") {
+		t.Fatalf("missing list headers in message prefix %q", m.MsgPrefix)
+	}
+}
+
+// Messages from non-owners to a moderated list are delivered to the owners only.
+func TestAliasDeliverModerated(t *testing.T) {
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"mox.example.": {"127.0.0.10"}, // For mx check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"mox.example."}, // To get passed junk filter.
+		},
+		TXT: map[string][]string{
+			"mox.example.": {"v=spf1 ip4:127.0.0.10 -all"},
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	ownerCount := func(expect int) {
+		t.Helper()
+		acc, err := store.OpenAccount(pkglog, "☺", false)
+		tcheck(t, err, "open account")
+		defer func() {
+			err := acc.Close()
+			tcheck(t, err, "close account")
+		}()
+		n, err := bstore.QueryDB[store.Message](ctxbg, acc.DB).FilterEqual("Expunged", false).Count()
+		tcheck(t, err, "count messages")
+		if n != expect {
+			t.Fatalf("owner has %d messages, expected %d", n, expect)
+		}
+	}
+
+	var msg = strings.ReplaceAll(`From: <móx@mox.example>
+To: <moderated@mox.example>
+Subject: test
+
+test email
+`, "\n", "\r\n")
+
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "móx@mox.example"
+		rcptTo := "moderated@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), true, true, false)
+		ts.smtpErr(err, nil)
+
+		ts.checkCount("Inbox", 0) // Not delivered to member mjl@.
+		ownerCount(1)
+	})
+
+	msg = strings.ReplaceAll(`From: <☺@mox.example>
+To: <moderated@mox.example>
+Subject: test
+
+test email
+`, "\n", "\r\n")
+
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "☺@mox.example"
+		rcptTo := "moderated@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), true, true, false)
+		ts.smtpErr(err, nil)
+
+		ts.checkCount("Inbox", 1) // Owner can post directly.
+		ownerCount(1)
+	})
+}
+
+// Mailing list with email commands, subscribers posting, and distribution to
+// subscribers through the queue.
+func TestAliasMailingList(t *testing.T) {
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // To get passed junk filter.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mlist/mox.conf"), resolver)
+	defer ts.close()
+
+	err := mlist.Init()
+	tcheck(t, err, "mlist init")
+	defer func() {
+		err := mlist.Close()
+		tcheck(t, err, "mlist close")
+	}()
+
+	const list = "list@mox.example"
+	deliver := func(rcptTo, subject string, expErr *smtpclient.Error) {
+		t.Helper()
+		msg := strings.ReplaceAll(`From: <other@example.org>
+To: <list@mox.example>
+Subject: `+subject+`
+
+test email
+`, "\n", "\r\n")
+		ts.run(func(client *smtpclient.Client) {
+			err := client.Deliver(ctxbg, "other@example.org", rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+	queued := func(expect int) {
+		t.Helper()
+		n, err := bstore.QueryDB[queue.Msg](ctxbg, queue.DB).Count()
+		tcheck(t, err, "count queued messages")
+		tcompare(t, n, expect)
+	}
+
+	// Not a member or subscriber.
+	deliver(list, "test", &smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SePol7ExpnProhibited2})
+
+	// Subscribe, a confirmation message is queued, not delivered to members.
+	deliver("list+subscribe@mox.example", "subscribe", nil)
+	ts.checkCount("Inbox", 0)
+	queued(1)
+	subs, err := mlist.Subscribers(ctxbg, list)
+	tcheck(t, err, "subscribers")
+	if len(subs) != 1 || subs[0].Confirmed {
+		t.Fatalf("unexpected subscribers %#v", subs)
+	}
+
+	deliver("list+bogus@mox.example", "bogus", &smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SeAddr1UnknownDestMailbox1})
+	deliver("list+confirm-"+subs[0].Token+"@mox.example", "confirm", nil)
+	ok, err := mlist.IsSubscriber(ctxbg, list, smtp.NewAddress("other", dns.Domain{ASCII: "example.org"}))
+	tcheck(t, err, "is subscriber")
+	tcompare(t, ok, true)
+
+	// Subscribers can post. The message is delivered to members and sent to other
+	// subscribers.
+	_, err = mlist.SubscriberAdd(ctxbg, list, smtp.NewAddress("third", dns.Domain{ASCII: "example.net"}), false)
+	tcheck(t, err, "add subscriber")
+	deliver(list, "test", nil)
+	ts.checkCount("Inbox", 1)
+	queued(2)
+	qm, err := bstore.QueryDB[queue.Msg](ctxbg, queue.DB).FilterEqual("IsListMessage", true).Get()
+	tcheck(t, err, "get list message from queue")
+	tcompare(t, qm.Recipient().String(), "third@example.net")
+}
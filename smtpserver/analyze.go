@@ -1,10 +1,13 @@
 package smtpserver
 
 import (
+	"cmp"
 	"context"
 	"fmt"
+	"io"
 	"log/slog"
 	"net"
+	"net/textproto"
 	"os"
 	"strings"
 	"time"
@@ -12,17 +15,21 @@ import (
 	"github.com/mjl-/bstore"
 
 	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/contentrep"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dmarc"
 	"github.com/mjl-/mox/dmarcrpt"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/dnsbl"
 	"github.com/mjl-/mox/iprev"
+	"github.com/mjl-/mox/junk"
 	"github.com/mjl-/mox/message"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/publicsuffix"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/spamfilter"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/subjectpass"
 	"github.com/mjl-/mox/tlsrpt"
@@ -46,6 +53,9 @@ type delivery struct {
 	dkimResults      []dkim.Result
 	iprevStatus      iprev.Status
 	smtputf8         bool
+	tlsSuspicious    bool                 // Whether the TLS client fingerprint is listed as suspicious.
+	msgHeaders       textproto.MIMEHeader // Nil if the message could not be parsed.
+	exempt           mox.Exempt           // Exempted checks for the remote IP.
 }
 
 type analysis struct {
@@ -56,35 +66,50 @@ type analysis struct {
 	secode              string
 	userError           bool
 	errmsg              string
-	err                 error              // For our own logging, not sent to remote.
-	dmarcReport         *dmarcrpt.Feedback // Validated DMARC aggregate report, not yet stored.
-	tlsReport           *tlsrpt.Report     // Validated TLS report, not yet stored.
-	reason              string             // If non-empty, reason for this decision. Values from reputationMethod and reason* below.
-	reasonText          []string           // Additional details for reason, human-readable, added to X-Mox-Reason header.
-	dmarcOverrideReason string             // If set, one of dmarcrpt.PolicyOverride
+	err                 error                   // For our own logging, not sent to remote.
+	dmarcReport         *dmarcrpt.Feedback      // Validated DMARC aggregate report, not yet stored.
+	dmarcFailureReport  *dmarcrpt.FailureReport // Validated DMARC failure report, not yet stored.
+	tlsReport           *tlsrpt.Report          // Validated TLS report, not yet stored.
+	reason              string                  // If non-empty, reason for this decision. Values from reputationMethod and reason* below.
+	reasonText          []string                // Additional details for reason, human-readable, added to X-Mox-Reason header.
+	dmarcOverrideReason string                  // If set, one of dmarcrpt.PolicyOverride
 	// Additional headers to add during delivery. Used for reasons a message to a
 	// dmarc/tls reporting address isn't processed.
 	headers string
 }
 
 const (
-	reasonListAllow         = "list-allow"
-	reasonDMARCPolicy       = "dmarc-policy"
-	reasonReputationError   = "reputation-error"
-	reasonReporting         = "reporting"
-	reasonSPFPolicy         = "spf-policy"
-	reasonJunkClassifyError = "junk-classify-error"
-	reasonJunkFilterError   = "junk-filter-error"
-	reasonGiveSubjectpass   = "give-subjectpass"
-	reasonNoBadSignals      = "no-bad-signals"
-	reasonJunkContent       = "junk-content"
-	reasonJunkContentStrict = "junk-content-strict"
-	reasonDNSBlocklisted    = "dns-blocklisted"
-	reasonSubjectpass       = "subjectpass"
-	reasonSubjectpassError  = "subjectpass-error"
-	reasonIPrev             = "iprev"     // No or mild junk reputation signals, and bad iprev.
-	reasonHighRate          = "high-rate" // Too many messages, not added to rejects.
-	reasonMsgAuthRequired   = "msg-auth-required"
+	reasonListAllow            = "list-allow"
+	reasonDMARCPolicy          = "dmarc-policy"
+	reasonReputationError      = "reputation-error"
+	reasonReporting            = "reporting"
+	reasonSPFPolicy            = "spf-policy"
+	reasonJunkClassifyError    = "junk-classify-error"
+	reasonJunkFilterError      = "junk-filter-error"
+	reasonGiveSubjectpass      = "give-subjectpass"
+	reasonNoBadSignals         = "no-bad-signals"
+	reasonJunkContent          = "junk-content"
+	reasonJunkContentStrict    = "junk-content-strict"
+	reasonDNSBlocklisted       = "dns-blocklisted"
+	reasonSubjectpass          = "subjectpass"
+	reasonSubjectpassError     = "subjectpass-error"
+	reasonIPrev                = "iprev"     // No or mild junk reputation signals, and bad iprev.
+	reasonHighRate             = "high-rate" // Too many messages, not added to rejects.
+	reasonMsgAuthRequired      = "msg-auth-required"
+	reasonSenderAllow          = "sender-allow"                  // Admin override in server-wide sender reputation.
+	reasonSenderQuarantine     = "sender-quarantine"             // Admin override in server-wide sender reputation.
+	reasonSenderReputation     = "sender-reputation"             // Server-wide sender reputation, when per-account reputation is inconclusive.
+	reasonSpamFilter           = "spam-filter"                   // External spam filter considers message spam.
+	reasonSpamFilterReject     = "spam-filter-reject"            // Score of external spam filter at or above reject score.
+	reasonSpamFilterQuarantine = "spam-filter-quarantine"        // Score of external spam filter at or above quarantine score.
+	reasonContentReputation    = "content-reputation"            // URL domain or attachment hash listed in a reputation source.
+	reasonContentQuarantine    = "content-reputation-quarantine" // Listed URL domain or attachment hash, delivered to Junk mailbox.
+	reasonJunkScore            = "junk-score"                    // Spaminess score above junk threshold of spam actions, delivered to Junk mailbox.
+	reasonJunkQuarantine       = "junk-quarantine"               // Spaminess score above threshold with quarantine action, stored in rejects mailbox.
+	reasonSenderListAllow      = "sender-list-allow"             // Verified message From address allowed in sender lists of account.
+	reasonSenderListReject     = "sender-list-reject"            // Message From or SMTP MAIL FROM address rejected by sender lists of account, not added to rejects.
+	reasonSenderListDiscard    = "sender-list-discard"           // Accepted but not delivered due to sender lists of account.
+	reasonExempt               = "exempt"                        // Remote IP exempted from junk filtering in config.
 )
 
 func isListDomain(d delivery, ld dns.Domain) bool {
@@ -113,6 +138,10 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 	// purged, or by filling the disk. We check both cases for IP's and networks.
 	var rateError bool // Whether returned error represents a rate error.
 	err := d.acc.DB.Read(ctx, func(tx *bstore.Tx) (retErr error) {
+		if d.exempt.RateLimits {
+			return nil
+		}
+
 		now := time.Now()
 		defer func() {
 			log.Debugx("checking message and size delivery rates", retErr, slog.Duration("duration", time.Since(now)))
@@ -185,12 +214,12 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		log.Errorx("checking delivery rates", err)
 		metricDelivery.WithLabelValues("checkrates", "").Inc()
 		addReasonText("checking delivery rates: %v", err)
-		return analysis{d, false, "", smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing", err, nil, nil, reasonReputationError, reasonText, "", headers}
+		return analysis{d, false, "", smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing", err, nil, nil, nil, reasonReputationError, reasonText, "", headers}
 	} else if err != nil {
 		log.Debugx("refusing due to high delivery rate", err)
 		metricDelivery.WithLabelValues("highrate", "").Inc()
 		addReasonText("high delivery rate")
-		return analysis{d, false, "", smtp.C452StorageFull, smtp.SeMailbox2Full2, true, err.Error(), err, nil, nil, reasonHighRate, reasonText, "", headers}
+		return analysis{d, false, "", smtp.C452StorageFull, smtp.SeMailbox2Full2, true, err.Error(), err, nil, nil, nil, reasonHighRate, reasonText, "", headers}
 	}
 
 	mailbox := d.destination.Mailbox
@@ -288,7 +317,7 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			})
 			if mberr != nil {
 				addReasonText("error setting original destination mailbox for rejected message: %v", mberr)
-				return analysis{d, false, mailbox, smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing", err, nil, nil, reasonReputationError, reasonText, dmarcOverrideReason, headers}
+				return analysis{d, false, mailbox, smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing", err, nil, nil, nil, reasonReputationError, reasonText, dmarcOverrideReason, headers}
 			}
 			d.m.MailboxID = 0 // We plan to reject, no need to set intended MailboxID.
 		}
@@ -303,7 +332,7 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			log.Info("accepting reject to configured mailbox due to ruleset")
 			addReasonText("accepting reject to mailbox due to ruleset")
 		}
-		return analysis{d, accept, mailbox, code, secode, err == nil, errmsg, err, nil, nil, reason, reasonText, dmarcOverrideReason, headers}
+		return analysis{d, accept, mailbox, code, secode, err == nil, errmsg, err, nil, nil, nil, reason, reasonText, dmarcOverrideReason, headers}
 	}
 
 	if d.dmarcUse && d.dmarcResult.Reject {
@@ -319,12 +348,28 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 	// If destination is the DMARC reporting mailbox, do additional checks and keep
 	// track of the report. We'll check reputation, defaulting to accept.
 	var dmarcReport *dmarcrpt.Feedback
+	var dmarcFailureReport *dmarcrpt.FailureReport
 	if d.destination.DMARCReports {
 		// Messages with DMARC aggregate reports must have a DMARC pass. ../rfc/7489:1866
+		// We require the same for failure reports.
 		if d.dmarcResult.Status != dmarc.StatusPass {
 			log.Info("received dmarc aggregate report without dmarc pass, not processing as dmarc report")
 			headers += "X-Mox-DMARCReport-Error: no DMARC pass\r\n"
-		} else if report, err := dmarcrpt.ParseMessageReport(log.Logger, store.FileMsgReader(d.m.MsgPrefix, d.dataFile)); err != nil {
+		} else if report, err := dmarcrpt.ParseMessageReport(log.Logger, store.FileMsgReader(d.m.MsgPrefix, d.dataFile)); err == dmarcrpt.ErrNoReport {
+			// Not an aggregate report, perhaps a failure report.
+			if fr, err := dmarcrpt.ParseMessageFailureReport(log.Logger, store.FileMsgReader(d.m.MsgPrefix, d.dataFile)); err != nil {
+				log.Infox("parsing dmarc failure report", err)
+				headers += "X-Mox-DMARCReport-Error: could not parse report\r\n"
+			} else if d, err := dns.ParseDomain(fr.ReportedDomain); err != nil {
+				log.Infox("parsing reported domain in dmarc failure report", err)
+				headers += "X-Mox-DMARCReport-Error: could not parse reported domain\r\n"
+			} else if _, ok := mox.Conf.Domain(d); !ok {
+				log.Info("dmarc failure report for domain not configured, ignoring", slog.Any("domain", d))
+				headers += "X-Mox-DMARCReport-Error: reported domain unrecognized\r\n"
+			} else {
+				dmarcFailureReport = fr
+			}
+		} else if err != nil {
 			log.Infox("parsing dmarc aggregate report", err)
 			headers += "X-Mox-DMARCReport-Error: could not parse report\r\n"
 		} else if d, err := dns.ParseDomain(report.PolicyPublished.Domain); err != nil {
@@ -410,6 +455,123 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		return reject(code, smtp.SePol7MultiAuthFails26, msg, nil, reasonMsgAuthRequired)
 	}
 
+	// The sender lists of the account, managed by the user, take precedence over
+	// reputation. Rejects and discards match both the message From and SMTP MAIL FROM
+	// address, allows only a verified message From address.
+	var fromEntry, mailFromEntry *store.SenderListEntry
+	err = d.acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		var err error
+		fromEntry, err = store.SenderListMatch(tx, d.m.MsgFromLocalpart, d.m.MsgFromDomain)
+		if err == nil {
+			mailFromEntry, err = store.SenderListMatch(tx, d.m.MailFromLocalpart, d.m.MailFromDomain)
+		}
+		return err
+	})
+	if err != nil {
+		log.Errorx("looking up sender lists", err)
+		addReasonText("looking up sender lists: %v", err)
+		return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, reasonReputationError)
+	}
+	blocked := fromEntry
+	if blocked == nil || blocked.Action == store.SenderListAllow {
+		blocked = mailFromEntry
+	}
+	if blocked != nil && blocked.Action == store.SenderListDiscard {
+		addReasonText("sender matches %q in sender lists, discarding", blocked.Pattern)
+		return analysis{
+			d:                   d,
+			accept:              true,
+			mailbox:             mailbox,
+			reason:              reasonSenderListDiscard,
+			reasonText:          reasonText,
+			dmarcOverrideReason: dmarcOverrideReason,
+			headers:             headers,
+		}
+	} else if blocked != nil && blocked.Action == store.SenderListReject {
+		addReasonText("sender matches %q in sender lists, rejecting", blocked.Pattern)
+		return analysis{d, false, mailbox, smtp.C550MailboxUnavail, smtp.SePol7DeliveryUnauth1, true, "not accepting messages from sender", nil, nil, nil, nil, reasonSenderListReject, reasonText, dmarcOverrideReason, headers}
+	} else if fromEntry != nil && d.m.MsgFromValidated {
+		addReasonText("verified sender matches %q in sender lists, allowing", fromEntry.Pattern)
+		return analysis{
+			d:                   d,
+			accept:              true,
+			mailbox:             mailbox,
+			dmarcReport:         dmarcReport,
+			dmarcFailureReport:  dmarcFailureReport,
+			tlsReport:           tlsReport,
+			reason:              reasonSenderListAllow,
+			reasonText:          reasonText,
+			dmarcOverrideReason: dmarcOverrideReason,
+			headers:             headers,
+		}
+	}
+
+	// Deliveries from trusted networks, e.g. internal relays, are not evaluated for
+	// junk.
+	if d.exempt.JunkFilter {
+		addReasonText("remote ip exempted from junk filtering")
+		return analysis{
+			d:                   d,
+			accept:              true,
+			mailbox:             mailbox,
+			dmarcReport:         dmarcReport,
+			dmarcFailureReport:  dmarcFailureReport,
+			tlsReport:           tlsReport,
+			reason:              reasonExempt,
+			reasonText:          reasonText,
+			dmarcOverrideReason: dmarcOverrideReason,
+			headers:             headers,
+		}
+	}
+
+	// The server-wide reputation of the sending domain and IP, aggregated over all
+	// accounts. The domain is only used for a validated From address. An override by
+	// the admin takes precedence over the per-account reputation.
+	var senderDomain string
+	if d.m.MsgFromValidated && !d.m.IsForward {
+		senderDomain = d.m.MsgFromOrgDomain
+	}
+	senderEval, err := reputationdb.Evaluate(ctx, senderDomain, d.m.RemoteIPMasked1)
+	if err != nil {
+		log.Errorx("evaluating server-wide sender reputation, continuing", err)
+	}
+	switch senderEval.Override {
+	case reputationdb.OverrideAllow:
+		addReasonText("%s", senderEval.Text)
+		return analysis{
+			d:                   d,
+			accept:              true,
+			mailbox:             mailbox,
+			dmarcReport:         dmarcReport,
+			dmarcFailureReport:  dmarcFailureReport,
+			tlsReport:           tlsReport,
+			reason:              reasonSenderAllow,
+			reasonText:          reasonText,
+			dmarcOverrideReason: dmarcOverrideReason,
+			headers:             headers,
+		}
+	case reputationdb.OverrideQuarantine:
+		addReasonText("%s", senderEval.Text)
+		junkMailbox, err := accountJunkMailbox(ctx, d.acc)
+		if err != nil {
+			log.Errorx("looking up junk mailbox", err)
+			addReasonText("looking up junk mailbox: %v", err)
+			return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, reasonReputationError)
+		} else if junkMailbox == "" {
+			addReasonText("no junk mailbox for quarantine")
+			return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reasonSenderQuarantine)
+		}
+		return analysis{
+			d:                   d,
+			accept:              true,
+			mailbox:             junkMailbox,
+			reason:              reasonSenderQuarantine,
+			reasonText:          reasonText,
+			dmarcOverrideReason: dmarcOverrideReason,
+			headers:             headers,
+		}
+	}
+
 	// Determine if message is acceptable based on DMARC domain, DKIM identities, or
 	// host-based reputation.
 	var isjunk *bool
@@ -457,6 +619,7 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 				accept:              true,
 				mailbox:             mailbox,
 				dmarcReport:         dmarcReport,
+				dmarcFailureReport:  dmarcFailureReport,
 				tlsReport:           tlsReport,
 				reason:              reason,
 				reasonText:          reasonText,
@@ -465,14 +628,15 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			}
 		}
 		return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, string(method))
-	} else if dmarcReport != nil || tlsReport != nil {
-		log.Info("accepting message with dmarc aggregate report or tls report without reputation")
+	} else if dmarcReport != nil || dmarcFailureReport != nil || tlsReport != nil {
+		log.Info("accepting message with dmarc aggregate report, dmarc failure report or tls report without reputation")
 		addReasonText("message inconclusive reputation but with dmarc or tls report")
 		return analysis{
 			d:                   d,
 			accept:              true,
 			mailbox:             mailbox,
 			dmarcReport:         dmarcReport,
+			dmarcFailureReport:  dmarcFailureReport,
 			tlsReport:           tlsReport,
 			reason:              reasonReporting,
 			reasonText:          reasonText,
@@ -480,6 +644,26 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			headers:             headers,
 		}
 	}
+
+	// Without conclusive reputation in this account, use the server-wide sender
+	// reputation.
+	if senderEval.Junk != nil {
+		addReasonText("%s", senderEval.Text)
+	}
+	if senderEval.Junk != nil && *senderEval.Junk {
+		return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reasonSenderReputation)
+	} else if senderEval.Junk != nil {
+		return analysis{
+			d:                   d,
+			accept:              true,
+			mailbox:             mailbox,
+			reason:              reasonSenderReputation,
+			reasonText:          reasonText,
+			dmarcOverrideReason: dmarcOverrideReason,
+			headers:             headers,
+		}
+	}
+
 	// If there was no previous message from sender or its domain, and we have an SPF
 	// (soft)fail, reject the message.
 	switch method {
@@ -536,8 +720,12 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 	reason = reasonNoBadSignals
 	accept := true
 	var junkSubjectpass bool
+	var haveJunkFilter bool
+	var spamActions config.SpamActions
+	var junkByScore bool // Whether to deliver to the Junk mailbox if accepted.
 	f, jf, err := d.acc.OpenJunkFilter(ctx, log)
 	if err == nil {
+		haveJunkFilter = true
 		defer func() {
 			err := f.Close()
 			log.Check(err, "closing junkfilter")
@@ -548,6 +736,31 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			addReasonText("classify message error: %v", err)
 			return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, reasonJunkClassifyError)
 		}
+
+		// Combine with the shared junk filter of the domain of the account, if configured.
+		// If only one of the filters has a significant score, we use that score.
+		accConf, _ := d.acc.Conf()
+		err = store.WithSharedJunkFilter(ctx, log, accConf.DNSDomain, func(sjf *junk.Filter, sconf config.SharedJunkFilter) error {
+			shared, err := sjf.ClassifyMessageReader(ctx, store.FileMsgReader(d.m.MsgPrefix, d.dataFile), d.m.Size)
+			if err != nil {
+				return err
+			}
+			prob := result.Probability
+			if shared.Significant && result.Significant {
+				prob = (1-sconf.Weight)*result.Probability + sconf.Weight*shared.Probability
+			} else if shared.Significant {
+				prob = shared.Probability
+			}
+			addReasonText("shared junk filter: spamscore %.2f, significant %v, weight %.2f, combined spamscore %.2f", shared.Probability, shared.Significant, sconf.Weight, prob)
+			result.Probability = prob
+			result.Significant = result.Significant || shared.Significant
+			return nil
+		})
+		if err != nil && err != store.ErrNoJunkFilter {
+			log.Errorx("classifying with shared junk filter, continuing with account junk filter", err)
+			addReasonText("shared junk filter error: %v", err)
+		}
+
 		// todo: if isjunk is not nil (i.e. there was inconclusive reputation), use it in the probability calculation. give reputation a score of 0.25 or .75 perhaps?
 		// todo: if there aren't enough historic messages, we should just let messages in.
 		// todo: we could require nham and nspam to be above a certain number when there were plenty of words in the message, and in the database. can indicate a spammer is misspelling words. however, it can also mean a message in a different language/script...
@@ -591,6 +804,12 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			log.Info("setting junk threshold due to plaintext smtp", slog.Float64("threshold", threshold))
 			reason = reasonJunkContentStrict
 			thresholdRemark = " (stricter due to missing tls)"
+		} else if d.tlsSuspicious && threshold > 0.25 {
+			threshold = 0.25
+			log.Info("setting junk threshold due to suspicious tls client fingerprint", slog.Float64("threshold", threshold))
+			metricDeliveryTLSFingerprint.WithLabelValues("suspicious").Inc()
+			reason = reasonJunkContentStrict
+			thresholdRemark = " (stricter due to suspicious tls client fingerprint)"
 		} else if (rs == nil || !rs.IsForward) && threshold > 0.25 && !rcptToMatch(d.msgTo) && !rcptToMatch(d.msgCc) {
 			// A common theme in junk messages is your recipient address not being in the To/Cc
 			// headers. We may be in Bcc, but that's unusual for first-time senders. Some
@@ -603,11 +822,40 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		}
 		accept = result.Probability <= threshold || (!result.Significant && !suspiciousIPrevFail)
 		junkSubjectpass = result.Probability < threshold-0.2
+
+		// Spam actions of the account, with defaults from its domain, determine what we
+		// do with accepted messages with a moderate score, and with rejected messages.
+		var domainActions *config.SpamActions
+		if dc, ok := mox.Conf.Domain(accConf.DNSDomain); ok {
+			domainActions = dc.SpamActions
+		}
+		spamActions = jf.EffectiveSpamActions(domainActions)
+		if result.Significant && spamActions.TagThreshold > 0 && result.Probability > spamActions.TagThreshold {
+			headers += fmt.Sprintf("X-Mox-Spam-Flag: YES\r\nX-Mox-Spam-Score: %.2f\r\n", result.Probability)
+		}
+		junkByScore = accept && result.Significant && spamActions.JunkThreshold > 0 && result.Probability > spamActions.JunkThreshold
+
 		log.Info("content analyzed",
 			slog.Bool("accept", accept),
 			slog.Float64("contentprob", result.Probability),
 			slog.Bool("contentsignificant", result.Significant),
-			slog.Bool("subjectpass", junkSubjectpass))
+			slog.Bool("subjectpass", junkSubjectpass),
+			slog.Bool("junkbyscore", junkByScore))
+
+		// Stored with the message, the decision is added after analysis.
+		verdict := store.MessageJunkVerdict{
+			Classified:  true,
+			Score:       result.Probability,
+			Threshold:   threshold,
+			Significant: result.Significant,
+		}
+		for _, w := range result.Hams {
+			verdict.HamWords = append(verdict.HamWords, store.MessageJunkWord{Word: w.Word, Score: w.Score})
+		}
+		for _, w := range result.Spams {
+			verdict.SpamWords = append(verdict.SpamWords, store.MessageJunkWord{Word: w.Word, Score: w.Score})
+		}
+		d.m.JunkVerdict = &verdict
 
 		s := "content: "
 		if accept {
@@ -619,6 +867,9 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 			s += " (not significant)"
 		}
 		s += fmt.Sprintf(", spamscore %.2f, threshold %.2f%s", result.Probability, threshold, thresholdRemark)
+		if junkByScore {
+			s += fmt.Sprintf(", above junk threshold %.2f", spamActions.JunkThreshold)
+		}
 		s += " (ham words: "
 		for i, w := range result.Hams {
 			if i > 0 {
@@ -651,21 +902,124 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		addReasonText("no junk filter configured")
 	}
 
+	// Check with the external spam filter, if configured, and combine its verdict with
+	// the Bayesian junk filter.
+	if sf := mox.Conf.Static.SpamFilter; sf != nil {
+		filter, result, err := checkSpamFilter(ctx, log, sf, d)
+		if err != nil {
+			log.Errorx("checking message with external spam filter, continuing without", err, slog.String("filter", filter.Name()))
+			addReasonText("external spam filter %s: %v", filter.Name(), err)
+		} else {
+			headers += result.Headers(filter.Name())
+			log.Info("external spam filter checked message",
+				slog.String("filter", filter.Name()),
+				slog.Bool("spam", result.Spam),
+				slog.Float64("score", result.Score),
+				slog.Any("symbols", result.Symbols))
+			addReasonText("external spam filter %s: spam %v, score %.2f, threshold %.2f, symbols %s", filter.Name(), result.Spam, result.Score, result.Threshold, strings.Join(result.Symbols, ","))
+
+			if sf.RejectScore != 0 && result.Score >= sf.RejectScore {
+				addReasonText("external spam filter score at or above reject score %.2f", sf.RejectScore)
+				return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reasonSpamFilterReject)
+			} else if sf.QuarantineScore != 0 && result.Score >= sf.QuarantineScore {
+				addReasonText("external spam filter score at or above quarantine score %.2f", sf.QuarantineScore)
+				junkMailbox, err := accountJunkMailbox(ctx, d.acc)
+				if err != nil {
+					log.Errorx("looking up junk mailbox", err)
+					addReasonText("looking up junk mailbox: %v", err)
+					return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, reasonSpamFilterQuarantine)
+				} else if junkMailbox == "" {
+					addReasonText("no junk mailbox for quarantine")
+					return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reasonSpamFilterQuarantine)
+				}
+				return analysis{
+					d:                   d,
+					accept:              true,
+					mailbox:             junkMailbox,
+					reason:              reasonSpamFilterQuarantine,
+					reasonText:          reasonText,
+					dmarcOverrideReason: dmarcOverrideReason,
+					headers:             headers,
+				}
+			}
+
+			bayesAccept := accept
+			switch {
+			case !haveJunkFilter || sf.Policy == config.SpamFilterPolicyExternal:
+				accept = !result.Spam
+			case sf.Policy == config.SpamFilterPolicyBoth:
+				accept = bayesAccept || !result.Spam
+			default:
+				accept = bayesAccept && !result.Spam
+			}
+			if !accept && result.Spam {
+				reason = reasonSpamFilter
+				junkSubjectpass = false
+			}
+		}
+	}
+
+	// Check URLs and attachments against reputation sources, if configured.
+	if cr := mox.Conf.Static.ContentReputation; cr != nil && accept {
+		listings, err := checkContentReputation(ctx, log, resolver, cr, d)
+		if err != nil {
+			log.Infox("checking content reputation, continuing", err)
+			addReasonText("content reputation: %v", err)
+		}
+		if len(listings) > 0 {
+			for _, l := range listings {
+				addReasonText("content reputation: %s", l)
+			}
+			log.Info("url or attachment of message listed", slog.Any("listings", listings))
+			if !cr.Quarantine {
+				return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reasonContentReputation)
+			}
+			junkMailbox, err := accountJunkMailbox(ctx, d.acc)
+			if err != nil {
+				log.Errorx("looking up junk mailbox", err)
+				addReasonText("looking up junk mailbox: %v", err)
+				return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, reasonContentQuarantine)
+			} else if junkMailbox == "" {
+				addReasonText("no junk mailbox for quarantine")
+				return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reasonContentQuarantine)
+			}
+			return analysis{
+				d:                   d,
+				accept:              true,
+				mailbox:             junkMailbox,
+				reason:              reasonContentQuarantine,
+				reasonText:          reasonText,
+				dmarcOverrideReason: dmarcOverrideReason,
+				headers:             headers,
+			}
+		} else if err == nil {
+			addReasonText("content reputation: no listed urls or attachments")
+		}
+	}
+
 	// If content looks good, we'll still look at DNS block lists for a reason to
 	// reject. We normally won't get here if we've communicated with this sender
 	// before.
 	var dnsblocklisted bool
 	if accept {
+		// Results are stored with the message.
+		addDNSBLAuth := func(zone dns.Domain, status string) {
+			if d.m.Auth != nil {
+				d.m.Auth.DNSBLs = append(d.m.Auth.DNSBLs, store.MessageAuthDNSBL{Zone: zone.Name(), Status: status})
+			}
+		}
 		blocked := func(zone dns.Domain) bool {
 			dnsblctx, dnsblcancel := context.WithTimeout(ctx, 30*time.Second)
 			defer dnsblcancel()
-			if !checkDNSBLHealth(dnsblctx, log, resolver, zone) {
+			if !dnsbl.Healthy(dnsblctx, log.Logger, resolver, zone) {
 				log.Info("dnsbl not healthy, skipping", slog.Any("zone", zone))
+				addDNSBLAuth(zone, "skipped")
 				return false
 			}
 
-			status, expl, err := dnsbl.Lookup(dnsblctx, log.Logger, resolver, zone, net.ParseIP(d.m.RemoteIP))
+			status, expl, err := dnsbl.LookupCached(dnsblctx, log.Logger, resolver, zone, net.ParseIP(d.m.RemoteIP))
 			dnsblcancel()
+			addDNSBLAuth(zone, string(status))
 			if status == dnsbl.StatusFail {
 				log.Info("rejecting due to listing in dnsbl", slog.Any("zone", zone), slog.String("explanation", expl))
 				return true
@@ -690,6 +1044,27 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		}
 	}
 
+	if accept && junkByScore {
+		junkMailbox, err := accountJunkMailbox(ctx, d.acc)
+		if err != nil {
+			log.Errorx("looking up junk mailbox", err)
+			addReasonText("looking up junk mailbox: %v", err)
+			return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", err, reasonReputationError)
+		} else if junkMailbox != "" {
+			addReasonText("spamscore above junk threshold, delivering to junk mailbox")
+			return analysis{
+				d:                   d,
+				accept:              true,
+				mailbox:             junkMailbox,
+				reason:              reasonJunkScore,
+				reasonText:          reasonText,
+				dmarcOverrideReason: dmarcOverrideReason,
+				headers:             headers,
+			}
+		}
+		addReasonText("spamscore above junk threshold, but no junk mailbox")
+	}
+
 	if accept {
 		addReasonText("no known reputation and no bad signals")
 		return analysis{
@@ -703,6 +1078,26 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 		}
 	}
 
+	// With the quarantine action, a message the junk filter considers spam is
+	// accepted, so the sender cannot tell, but only stored in the rejects mailbox.
+	if (reason == reasonJunkContent || reason == reasonJunkContentStrict) && spamActions.HighAction == config.SpamHighActionQuarantine {
+		if accConf, _ := d.acc.Conf(); accConf.RejectsMailbox != "" {
+			addReasonText("quarantining in rejects mailbox per spam actions")
+			d.m.IsReject = true
+			d.m.Seen = true
+			return analysis{
+				d:                   d,
+				accept:              true,
+				mailbox:             accConf.RejectsMailbox,
+				reason:              reasonJunkQuarantine,
+				reasonText:          reasonText,
+				dmarcOverrideReason: dmarcOverrideReason,
+				headers:             headers,
+			}
+		}
+		addReasonText("no rejects mailbox for quarantine, rejecting")
+	}
+
 	if subjectpassKey != "" && d.dmarcResult.Status == dmarc.StatusPass && method == methodNone && (dnsblocklisted || junkSubjectpass) {
 		log.Info("permanent reject with subjectpass hint of moderately spammy email without reputation")
 		pass := subjectpass.Generate(log.Logger, d.msgFrom, []byte(subjectpassKey), time.Now())
@@ -713,6 +1108,64 @@ func analyze(ctx context.Context, log mlog.Log, resolver dns.Resolver, d deliver
 	return reject(smtp.C451LocalErr, smtp.SeSys3Other0, "error processing", nil, reason)
 }
 
+// screen applies first-contact screening of the account to an analysis. Messages
+// from unknown senders that would be delivered to the default mailbox of the
+// destination are delivered to the screening mailbox instead. Messages matched
+// by rulesets, forwarded and mailing list messages, DSNs and reports are not
+// screened.
+func screen(ctx context.Context, log mlog.Log, a analysis) analysis {
+	conf, _ := a.d.acc.Conf()
+	if conf.Screening == nil || !a.accept || a.reason == reasonSenderListDiscard || a.mailbox != cmp.Or(a.d.destination.Mailbox, "Inbox") {
+		return a
+	}
+	if a.d.m.DSN || a.d.m.IsForward || a.d.m.IsMailingList || a.d.m.IsReject {
+		return a
+	}
+	if a.d.destination.DMARCReports || a.d.destination.HostTLSReports || a.d.destination.DomainTLSReports {
+		return a
+	}
+	var known bool
+	err := a.d.acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		var err error
+		known, err = a.d.acc.ScreeningKnown(tx, *conf.Screening, *a.d.m)
+		return err
+	})
+	if err != nil {
+		log.Errorx("evaluating screening of sender", err)
+	} else if !known {
+		log.Info("delivering message from first-time sender to screening mailbox", slog.Any("msgfrom", a.d.msgFrom))
+		a.reasonText = append(a.reasonText, "first-time sender, delivering to screening mailbox for approval")
+		a.mailbox = conf.Screening.MailboxName()
+	}
+	return a
+}
+
+// categorize delivers bulk messages that would be delivered to the default
+// mailbox of the destination to the mailbox of their category, and marks them
+// with the keyword of the category. Junk analysis is not influenced.
+func categorize(log mlog.Log, a analysis) analysis {
+	conf, _ := a.d.acc.Conf()
+	if conf.Categories == nil || !a.accept || a.reason == reasonSenderListDiscard || a.mailbox != cmp.Or(a.d.destination.Mailbox, "Inbox") {
+		return a
+	}
+	if a.d.m.DSN || a.d.m.IsReject || a.d.msgHeaders == nil {
+		return a
+	}
+	if a.d.destination.DMARCReports || a.d.destination.HostTLSReports || a.d.destination.DomainTLSReports {
+		return a
+	}
+	category, cat := store.Categorize(*conf.Categories, *a.d.m, a.d.msgHeaders)
+	if cat == nil {
+		return a
+	}
+	log.Debug("categorized incoming message", slog.String("category", category), slog.String("mailbox", cat.Mailbox))
+	a.d.m.Keywords, _ = store.MergeKeywords(a.d.m.Keywords, []string{"$" + category})
+	if cat.Mailbox != "" {
+		a.mailbox = cat.Mailbox
+	}
+	return a
+}
+
 func isASCII(s string) bool {
 	for _, b := range []byte(s) {
 		if b >= 0x80 {
@@ -721,3 +1174,81 @@ func isASCII(s string) bool {
 	}
 	return true
 }
+
+// accountJunkMailbox returns the name of the mailbox with the Junk special-use
+// role in the account, or an empty string if there is none.
+func accountJunkMailbox(ctx context.Context, acc *store.Account) (string, error) {
+	var name string
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		mb, err := bstore.QueryTx[store.Mailbox](tx).FilterEqual("Expunged", false).FilterEqual("Junk", true).Get()
+		if err == nil {
+			name = mb.Name
+		} else if err != bstore.ErrAbsent {
+			return err
+		}
+		return nil
+	})
+	return name, err
+}
+
+// checkSpamFilter checks the message of a delivery with the configured external
+// spam filter.
+func checkSpamFilter(ctx context.Context, log mlog.Log, sf *config.SpamFilter, d delivery) (spamfilter.Filter, spamfilter.Result, error) {
+	var filter spamfilter.Filter
+	if sf.Rspamd != "" {
+		filter = spamfilter.Rspamd{URL: sf.Rspamd, Password: sf.RspamdPassword}
+	} else {
+		filter = spamfilter.Spamd{Address: sf.Spamd}
+	}
+	timeout := sf.Timeout
+	if timeout == 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	meta := spamfilter.Meta{
+		RemoteIP: net.ParseIP(d.m.RemoteIP),
+		Hostname: mox.Conf.Static.HostnameDomain.ASCII,
+		EHLO:     d.m.EHLODomain,
+		MailFrom: d.m.MailFrom,
+		RcptTo:   d.smtpRcptTo.String(),
+	}
+	msgr := io.NewSectionReader(store.FileMsgReader(d.m.MsgPrefix, d.dataFile), 0, d.m.Size)
+	result, err := filter.Check(ctx, log, msgr, d.m.Size, meta)
+	metricSpamFilter.WithLabelValues(filter.Name(), spamFilterResult(result, err)).Inc()
+	return filter, result, err
+}
+
+// checkContentReputation checks the URLs and attachments of the message of a
+// delivery against the configured reputation sources.
+func checkContentReputation(ctx context.Context, log mlog.Log, resolver dns.Resolver, cr *config.ContentReputation, d delivery) ([]contentrep.Listing, error) {
+	p, err := message.EnsurePart(log.Logger, false, store.FileMsgReader(d.m.MsgPrefix, d.dataFile), d.m.Size)
+	if err != nil {
+		return nil, fmt.Errorf("parsing message: %w", err)
+	}
+	content, err := contentrep.Extract(log.Logger, &p)
+	if err != nil {
+		return nil, fmt.Errorf("extracting urls and attachments: %w", err)
+	}
+	src := contentrep.Sources{
+		URIBLs:         cr.URIBLZones,
+		HashBLs:        cr.HashBLZones,
+		BlockedDomains: cr.BlockedDomainsParsed,
+		Hashes:         cr.Hashes,
+		MaxURLs:        cr.MaxURLs,
+		CacheTTL:       cr.CacheTTL,
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return contentrep.Check(ctx, log.Logger, resolver, src, content)
+}
+
+func spamFilterResult(r spamfilter.Result, err error) string {
+	if err != nil {
+		return "error"
+	} else if r.Spam {
+		return "spam"
+	}
+	return "ham"
+}
@@ -0,0 +1,64 @@
+package smtpserver
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/mox/dkim"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+// arcTrustedSealer returns the domain of a configured trusted ARC sealer that
+// recorded a DMARC pass for msgFromDomain, for messages that fail DMARC after
+// being modified by an intermediary. The zero domain is returned if the message
+// has no valid ARC chain, if the latest ARC set is not sealed by a trusted
+// sealer, or if the sealer did not record a DMARC pass for the From domain.
+func arcTrustedSealer(ctx context.Context, log mlog.Log, resolver dns.Resolver, msgFromDomain dns.Domain, msg io.ReaderAt) dns.Domain {
+	sets, err := dkim.ARCSets(msg)
+	if err != nil {
+		log.Debugx("parsing arc sets", err)
+		return dns.Domain{}
+	} else if len(sets) == 0 {
+		return dns.Domain{}
+	}
+	// Only the latest intermediary is the one that passed the message to us, earlier
+	// sets are vouched for by the latest.
+	last := sets[len(sets)-1]
+	if !slices.Contains(mox.Conf.Static.TrustedARCSealerDomains, last.Sealer) {
+		log.Debug("arc sealer not trusted", slog.Any("sealer", last.Sealer))
+		return dns.Domain{}
+	}
+
+	status, _, err := dkim.ARCVerify(ctx, log.Logger, resolver, msg)
+	if status != dkim.ARCPass {
+		log.Infox("arc chain of trusted sealer does not validate", err, slog.Any("sealer", last.Sealer), slog.Any("status", status))
+		return dns.Domain{}
+	}
+
+	ar, err := message.ParseAuthResults(last.AuthResults + "\r\n")
+	if err != nil {
+		log.Debugx("parsing arc authentication results of trusted sealer", err, slog.Any("sealer", last.Sealer))
+		return dns.Domain{}
+	}
+	for _, m := range ar.Methods {
+		if !strings.EqualFold(m.Method, "dmarc") || !strings.EqualFold(m.Result, "pass") {
+			continue
+		}
+		for _, p := range m.Props {
+			if !strings.EqualFold(p.Type, "header") || !strings.EqualFold(p.Property, "from") {
+				continue
+			}
+			if d, err := dns.ParseDomain(p.Value); err == nil && d == msgFromDomain {
+				return last.Sealer
+			}
+		}
+	}
+	log.Debug("trusted arc sealer did not record dmarc pass for message from domain", slog.Any("sealer", last.Sealer), slog.Any("fromdomain", msgFromDomain))
+	return dns.Domain{}
+}
@@ -2,36 +2,69 @@ package smtpserver
 
 import (
 	"context"
-	"errors"
-	"sync"
+	"log/slog"
+	"runtime/debug"
+	"slices"
 	"time"
 
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/dnsbl"
+	"github.com/mjl-/mox/metrics"
 	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
 )
 
-var dnsblHealth = struct {
-	sync.Mutex
-	zones map[dns.Domain]dnsblStatus
-}{
-	zones: map[dns.Domain]dnsblStatus{},
-}
-
-type dnsblStatus struct {
-	last time.Time
-	err  error // nil, dnsbl.ErrDNS or other
-}
+// Interval between background health checks of the DNSBLs of the listeners.
+const dnsblHealthInterval = 15 * time.Minute
 
-// checkDNSBLHealth checks healthiness of DNSBL "zone", keeping the result cached for 4 hours.
-func checkDNSBLHealth(ctx context.Context, log mlog.Log, resolver dns.Resolver, zone dns.Domain) (rok bool) {
-	dnsblHealth.Lock()
-	defer dnsblHealth.Unlock()
-	status, ok := dnsblHealth.zones[zone]
-	if !ok || time.Since(status.last) > 4*time.Hour {
-		status.err = dnsbl.CheckHealth(ctx, log.Logger, resolver, zone)
-		status.last = time.Now()
-		dnsblHealth.zones[zone] = status
+// dnsblHealthCheckerStart starts a goroutine that periodically checks the health
+// of the DNSBLs configured in the SMTP listeners. Dead lists are not used for
+// incoming deliveries, and are used again once they are healthy again.
+func dnsblHealthCheckerStart() {
+	var zones []dns.Domain
+	for _, l := range mox.Conf.Static.Listeners {
+		if !l.SMTP.Enabled {
+			continue
+		}
+		for _, zone := range l.SMTP.DNSBLZones {
+			if !slices.Contains(zones, zone) {
+				zones = append(zones, zone)
+			}
+		}
+	}
+	if len(zones) == 0 {
+		return
 	}
-	return status.err == nil || errors.Is(status.err, dnsbl.ErrDNS)
+
+	go func() {
+		log := mlog.New("smtpserver", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Smtpserver)
+			}
+		}()
+
+		resolver := dns.StrictResolver{Log: log.Logger}
+		ticker := time.NewTicker(dnsblHealthInterval)
+		defer ticker.Stop()
+		for {
+			for _, zone := range zones {
+				ctx, cancel := context.WithTimeout(mox.Shutdown, 30*time.Second)
+				err := dnsbl.CheckZoneHealth(ctx, log.Logger, resolver, zone)
+				cancel()
+				log.Debugx("dnsbl health check", err, slog.Any("zone", zone))
+			}
+
+			select {
+			case <-mox.Shutdown.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
 }
@@ -0,0 +1,190 @@
+package smtpserver
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webops"
+)
+
+// applyRules applies the webmail rules of the account to a just delivered message,
+// and queues forwards requested by matching rules. Forwards are sent from the
+// address the message was delivered to. Messages that were automatically
+// submitted, e.g. forwarded by a rule, are not forwarded again, preventing loops.
+func applyRules(ctx context.Context, log mlog.Log, acc *store.Account, m store.Message, part *message.Part, rcptTo smtp.Path, maxSize int64) error {
+	_, forwards, err := webops.RulesApply(ctx, log, acc, []int64{m.ID}, true)
+	if err != nil || len(forwards) == 0 {
+		return err
+	}
+
+	if h, err := part.Header(); err != nil {
+		return fmt.Errorf("parsing message header: %v", err)
+	} else if as := strings.ToLower(strings.TrimSpace(h.Get("Auto-Submitted"))); as != "" && as != "no" {
+		log.Info("not forwarding automatically submitted message for rule", slog.String("autosubmitted", as))
+		return nil
+	}
+
+	from := smtp.NewAddress(rcptTo.Localpart, rcptTo.IPDomain.Domain)
+	for _, fw := range forwards {
+		if err := queueRuleForward(ctx, log, acc, m, part, from, fw.To, maxSize); err != nil {
+			log.Errorx("forwarding message for rule", err, slog.Any("to", fw.To))
+		}
+	}
+	return nil
+}
+
+// queueRuleForward composes a message with m as attachment and adds it to the
+// queue for delivery to the addresses in to.
+func queueRuleForward(ctx context.Context, log mlog.Log, acc *store.Account, m store.Message, part *message.Part, from smtp.Address, to []string, maxSize int64) (rerr error) {
+	var rcpts []smtp.Path
+	var recipients []message.NameAddress
+	smtputf8 := from.Localpart.IsInternational()
+	for _, s := range to {
+		addr, err := smtp.ParseAddress(s)
+		if err != nil {
+			return fmt.Errorf("parsing forward address %q: %v", s, err)
+		}
+		rcpts = append(rcpts, addr.Path())
+		recipients = append(recipients, message.NameAddress{Address: addr})
+		smtputf8 = smtputf8 || addr.Localpart.IsInternational()
+	}
+
+	// Forwards count towards the sending limits.
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		msglimit, rcptlimit, err := acc.SendLimitReached(tx, rcpts)
+		if err != nil {
+			return fmt.Errorf("checking send limit: %v", err)
+		} else if msglimit >= 0 || rcptlimit >= 0 {
+			return errors.New("send limit reached")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	mr := acc.MessageReader(m)
+	defer func() {
+		err := mr.Close()
+		log.Check(err, "closing message reader")
+	}()
+	orig, err := io.ReadAll(mr)
+	if err != nil {
+		return fmt.Errorf("reading message: %v", err)
+	}
+
+	var subject string
+	if part.Envelope != nil {
+		subject = part.Envelope.Subject
+	}
+
+	var buf bytes.Buffer
+	xc := message.NewComposer(&buf, maxSize, smtputf8)
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(error); ok && errors.Is(err, message.ErrCompose) {
+			rerr = err
+			return
+		}
+		panic(x)
+	}()
+
+	xc.HeaderAddrs("From", []message.NameAddress{{Address: from}})
+	xc.HeaderAddrs("To", recipients)
+	xc.Subject("Fwd: " + subject)
+	messageID := fmt.Sprintf("<%s>", mox.MessageIDGen(xc.SMTPUTF8))
+	xc.Header("Message-Id", messageID)
+	xc.Header("Date", time.Now().Format(message.RFC5322Z))
+	xc.Header("User-Agent", "mox/"+moxvar.Version)
+	xc.Header("Auto-Submitted", "auto-forwarded")
+	xc.Header("MIME-Version", "1.0")
+
+	mp := multipart.NewWriter(xc)
+	xc.Header("Content-Type", fmt.Sprintf(`multipart/mixed; boundary="%s"`, mp.Boundary()))
+	xc.Line()
+
+	textBody, ct, cte := xc.TextPart("plain", "Forwarded by a message rule.\n")
+	textHdr := textproto.MIMEHeader{}
+	textHdr.Set("Content-Type", ct)
+	textHdr.Set("Content-Transfer-Encoding", cte)
+	textp, err := mp.CreatePart(textHdr)
+	xc.Checkf(err, "adding text part to message")
+	_, err = textp.Write(textBody)
+	xc.Checkf(err, "writing text part")
+
+	// The original message is attached as is, it may have 8bit data.
+	ahdr := textproto.MIMEHeader{}
+	ahdr.Set("Content-Type", "message/rfc822")
+	ahdr.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": "forwarded.eml"}))
+	if bytes.IndexFunc(orig, func(r rune) bool { return r >= 0x80 }) >= 0 {
+		xc.Has8bit = true
+		ahdr.Set("Content-Transfer-Encoding", "8bit")
+	}
+	ap, err := mp.CreatePart(ahdr)
+	xc.Checkf(err, "adding forwarded message part")
+	_, err = ap.Write(orig)
+	xc.Checkf(err, "writing forwarded message")
+
+	err = mp.Close()
+	xc.Checkf(err, "closing multipart")
+	xc.Flush()
+
+	data := buf.Bytes()
+	dkimHeaders, err := mox.DKIMSign(ctx, log, from.Path(), xc.SMTPUTF8, data)
+	log.Check(err, "dkim signing forwarded message")
+
+	f, err := store.CreateMessageTemp(log, "ruleforward")
+	if err != nil {
+		return fmt.Errorf("creating temp file: %w", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "forwarded message for rule")
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("writing forwarded message: %w", err)
+	}
+
+	now := time.Now()
+	qml := make([]queue.Msg, len(rcpts))
+	for i, rcpt := range rcpts {
+		size := int64(len(dkimHeaders)) + int64(len(data))
+		qml[i] = queue.MakeMsg(from.Path(), rcpt, xc.Has8bit, xc.SMTPUTF8, size, messageID, []byte(dkimHeaders), nil, now, "Fwd: "+subject)
+	}
+	if err := queue.Add(ctx, log, acc.Name, f, qml...); err != nil {
+		return fmt.Errorf("queueing forwarded message: %w", err)
+	}
+
+	err = acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, rcpt := range rcpts {
+			outgoing := store.Outgoing{Recipient: rcpt.XString(true)}
+			if err := tx.Insert(&outgoing); err != nil {
+				return fmt.Errorf("adding outgoing message: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("message forwarded for rule", slog.Any("to", to))
+	return nil
+}
@@ -39,6 +39,8 @@ import (
 
 	"github.com/mjl-/bstore"
 
+	"github.com/mjl-/mox/bimi"
+	"github.com/mjl-/mox/caldav"
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dmarc"
@@ -49,18 +51,24 @@ import (
 	"github.com/mjl-/mox/iprev"
 	"github.com/mjl-/mox/message"
 	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/oidc"
 	"github.com/mjl-/mox/publicsuffix"
 	"github.com/mjl-/mox/queue"
 	"github.com/mjl-/mox/ratelimit"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/scram"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/spf"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/tlsfp"
 	"github.com/mjl-/mox/tlsrpt"
 	"github.com/mjl-/mox/tlsrptdb"
+	"github.com/mjl-/mox/webops"
+	"github.com/mjl-/mox/webpush"
 )
 
 // We use panic and recover for error handling while executing commands.
@@ -146,7 +154,7 @@ var (
 	metricDelivery = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mox_smtpserver_delivery_total",
-			Help: "SMTP incoming message delivery from external source, not submission. Result values: delivered, reject, unknownuser, accounterror, delivererror. Reason indicates why a message was rejected/accepted.",
+			Help: "SMTP incoming message delivery from external source, not submission. Result values: delivered, reject, discard, unknownuser, accounterror, delivererror, spamtrap. Reason indicates why a message was rejected/accepted.",
 		},
 		[]string{
 			"result",
@@ -157,7 +165,7 @@ var (
 	metricSubmission = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mox_smtpserver_submission_total",
-			Help: "SMTP server incoming submission results, known values (those ending with error are server errors): ok, badmessage, badfrom, badheader, messagelimiterror, recipientlimiterror, localserveerror, queueerror.",
+			Help: "SMTP server incoming submission results, known values (those ending with error are server errors): ok, badmessage, badfrom, badheader, messagelimiterror, recipientlimiterror, localserveerror, queueerror, suspended, throttled.",
 		},
 		[]string{
 			"result",
@@ -187,6 +195,25 @@ var (
 			"reason", // "eof", "sslv2", "unsupportedversions", "nottls", "alert-<num>-<msg>", "other"
 		},
 	)
+	metricDeliveryTLSFingerprint = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_smtpserver_delivery_tls_fingerprint_total",
+			Help: "Incoming deliveries over TLS connections with a configured client fingerprint.",
+		},
+		[]string{
+			"result", // "blocked", "suspicious"
+		},
+	)
+	metricSpamFilter = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_smtpserver_spamfilter_checks_total",
+			Help: "Incoming messages checked with an external spam filter.",
+		},
+		[]string{
+			"filter", // "rspamd", "spamd"
+			"result", // "ham", "spam", "error"
+		},
+	)
 )
 
 var jitterRand = mox.NewPseudoRand()
@@ -309,10 +336,12 @@ func Serve() {
 	for _, serve := range servers {
 		go serve()
 	}
+	dnsblHealthCheckerStart()
 }
 
 type conn struct {
-	cid int64
+	cid          int64
+	listenerName string
 
 	// OrigConn is the original (TCP) connection. We'll read from/write to conn, which
 	// can be wrapped in a tls.Server. We close origConn instead of conn because
@@ -322,8 +351,10 @@ type conn struct {
 	conn     net.Conn
 
 	tls           bool
-	extRequireTLS bool // Whether to announce and allow the REQUIRETLS extension.
-	viaHTTPS      bool // Whether the connection came in via the HTTPS port (using TLS ALPN).
+	extRequireTLS bool   // Whether to announce and allow the REQUIRETLS extension.
+	viaHTTPS      bool   // Whether the connection came in via the HTTPS port (using TLS ALPN).
+	tlsJA3        string // TLS client fingerprints, set after TLS handshake if the ClientHello could be parsed.
+	tlsJA4        string
 	resolver      dns.Resolver
 	// The "x" in the readers and writes indicate Read and Write errors use panic to
 	// propagate the error.
@@ -347,6 +378,7 @@ type conn struct {
 	ncmds                 int       // Number of commands processed. Used to abort connection when first incoming command is unknown/invalid.
 	dnsBLs                []dns.Domain
 	firstTimeSenderDelay  time.Duration
+	exempt                mox.Exempt // Exempted checks for the remote IP.
 
 	// If non-zero, taken into account during Read and Write. Set while processing DATA
 	// command, we don't want the entire delivery to take too long.
@@ -370,6 +402,7 @@ type conn struct {
 	requireTLS           *bool     // MAIL FROM with REQUIRETLS set.
 	futureRelease        time.Time // MAIL FROM with HOLDFOR or HOLDUNTIL.
 	futureReleaseRequest string    // For use in DSNs, either "for;" or "until;" plus original value. ../rfc/4865:305
+	mtPriority           int       // MAIL FROM with MT-PRIORITY, -9 to 9, 0 is normal priority. RFC 6710.
 	has8bitmime          bool      // If MAIL FROM parameter BODY=8BITMIME was sent. Required for SMTPUTF8.
 	smtputf8             bool      // todo future: we should keep track of this per recipient. perhaps only a specific recipient requires smtputf8, e.g. due to a utf8 localpart.
 	msgsmtputf8          bool      // Is SMTPUTF8 required for the received message. Default to the same value as `smtputf8`, but is re-evaluated after the whole message (envelope and data) is received.
@@ -390,11 +423,12 @@ type rcptAlias struct {
 type recipient struct {
 	Addr smtp.Path
 
-	// If account and alias are both not set, this is not for a local address. This is
-	// normal for submission, where messages are added to the queue. For incoming
-	// deliveries, this will result in an error.
-	Account *rcptAccount // If set, recipient address is for this local account.
-	Alias   *rcptAlias   // If set, for a local alias.
+	// If account and alias are both not set, and this is not a spamtrap, this is not
+	// for a local address. This is normal for submission, where messages are added to
+	// the queue. For incoming deliveries, this will result in an error.
+	Account  *rcptAccount // If set, recipient address is for this local account.
+	Alias    *rcptAlias   // If set, for a local alias.
+	Spamtrap bool         // If set, a spamtrap address, messages are not delivered.
 }
 
 func isClosed(err error) bool {
@@ -585,7 +619,8 @@ func (c *conn) tlsClientAuthVerifyPeerCertParsed(cert *x509.Certificate) error {
 // xtlsHandshakeAndAuthenticate performs the TLS handshake, and verifies a client
 // certificate if present.
 func (c *conn) xtlsHandshakeAndAuthenticate(conn net.Conn) {
-	tlsConn := tls.Server(conn, c.makeTLSConfig())
+	fpConn := &tlsfp.Conn{Conn: conn}
+	tlsConn := tls.Server(fpConn, c.makeTLSConfig())
 	c.conn = tlsConn
 
 	cidctx := context.WithValue(mox.Context, mlog.CidKey, c.cid)
@@ -595,7 +630,15 @@ func (c *conn) xtlsHandshakeAndAuthenticate(conn net.Conn) {
 	if !c.submission {
 		metricDeliveryStarttls.Inc()
 	}
-	if err := tlsConn.HandshakeContext(ctx); err != nil {
+	err := tlsConn.HandshakeContext(ctx)
+	if hello, herr := fpConn.ClientHello(); herr == nil {
+		c.tlsJA3 = hello.JA3()
+		c.tlsJA4 = hello.JA4()
+	} else {
+		c.log.Debugx("parsing tls clienthello for fingerprint", herr)
+	}
+	if err != nil {
+		c.log.Debug("tls handshake failed", slog.String("ja3", c.tlsJA3), slog.String("ja4", c.tlsJA4))
 		if !c.submission {
 			// Errors from crypto/tls mostly aren't typed. We'll have to look for strings...
 			reason := "other"
@@ -635,6 +678,8 @@ func (c *conn) xtlsHandshakeAndAuthenticate(conn net.Conn) {
 		slog.String("sni", cs.ServerName),
 		slog.Bool("resumed", cs.DidResume),
 		slog.Int("clientcerts", len(cs.PeerCertificates)),
+		slog.String("ja3", c.tlsJA3),
+		slog.String("ja4", c.tlsJA4),
 	}
 	if c.account != nil {
 		attrs = append(attrs,
@@ -643,6 +688,19 @@ func (c *conn) xtlsHandshakeAndAuthenticate(conn net.Conn) {
 		)
 	}
 	c.log.Debug("tls handshake completed", attrs...)
+
+	if !c.submission && c.tlsFingerprintListed(mox.Conf.Static.Listeners[c.listenerName].SMTP.TLSFingerprintsBlocked) {
+		metricDeliveryTLSFingerprint.WithLabelValues("blocked").Inc()
+		c.log.Info("rejecting connection with blocked tls client fingerprint", slog.String("ja3", c.tlsJA3), slog.String("ja4", c.tlsJA4))
+		c.xwritecodeline(smtp.C554TransactionFailed, smtp.SePol7Other0, "tls client not allowed", nil)
+		panic(cleanClose)
+	}
+}
+
+// tlsFingerprintListed returns whether the JA3 or JA4 fingerprint of the TLS
+// connection is in l.
+func (c *conn) tlsFingerprintListed(l []string) bool {
+	return c.tlsJA3 != "" && slices.Contains(l, c.tlsJA3) || c.tlsJA4 != "" && slices.Contains(l, c.tlsJA4)
 }
 
 // completely reset connection state as if greeting has just been sent.
@@ -669,6 +727,7 @@ func (c *conn) rset() {
 	c.requireTLS = nil
 	c.futureRelease = time.Time{}
 	c.futureReleaseRequest = ""
+	c.mtPriority = 0
 	c.has8bitmime = false
 	c.smtputf8 = false
 	c.msgsmtputf8 = false
@@ -885,6 +944,7 @@ func serve(listenerName string, cid int64, hostname dns.Domain, tlsConfig *tls.C
 
 	c := &conn{
 		cid:                   cid,
+		listenerName:          listenerName,
 		origConn:              origConn,
 		conn:                  nc,
 		submission:            submission,
@@ -902,6 +962,13 @@ func serve(listenerName string, cid int64, hostname dns.Domain, tlsConfig *tls.C
 		requireTLSForDelivery: requireTLSForDelivery,
 		dnsBLs:                dnsBLs,
 		firstTimeSenderDelay:  firstTimeSenderDelay,
+		exempt:                mox.Exempted(remoteIP, ""),
+	}
+	if c.exempt.DNSBL {
+		c.dnsBLs = nil
+	}
+	if c.exempt.Delays {
+		c.firstTimeSenderDelay = 0
 	}
 	var logmutex sync.Mutex
 	// Also see (and possibly update) c.logbg, for logging in a goroutine.
@@ -970,7 +1037,16 @@ func serve(listenerName string, cid int64, hostname dns.Domain, tlsConfig *tls.C
 	default:
 	}
 
-	if !limiterConnectionRate.Add(c.remoteIP, time.Now(), 1) {
+	if len(c.exempt.Comments) > 0 {
+		c.log.Info("connection from network with exemptions",
+			slog.Bool("junkfilter", c.exempt.JunkFilter),
+			slog.Bool("dnsbl", c.exempt.DNSBL),
+			slog.Bool("delays", c.exempt.Delays),
+			slog.Bool("ratelimits", c.exempt.RateLimits),
+			slog.Any("comments", c.exempt.Comments))
+	}
+
+	if !c.exempt.RateLimits && !limiterConnectionRate.Add(c.remoteIP, time.Now(), 1) {
 		c.xwritecodeline(smtp.C421ServiceUnavail, smtp.SePol7Other0, "connection rate from your ip or network too high, slow down please", nil)
 		return
 	}
@@ -983,12 +1059,14 @@ func serve(listenerName string, cid int64, hostname dns.Domain, tlsConfig *tls.C
 		return
 	}
 
-	if !limiterConnections.Add(c.remoteIP, time.Now(), 1) {
-		c.log.Debug("refusing connection due to many open connections", slog.Any("remoteip", c.remoteIP))
-		c.xwritecodeline(smtp.C421ServiceUnavail, smtp.SePol7Other0, "too many open connections from your ip or network", nil)
-		return
+	if !c.exempt.RateLimits {
+		if !limiterConnections.Add(c.remoteIP, time.Now(), 1) {
+			c.log.Debug("refusing connection due to many open connections", slog.Any("remoteip", c.remoteIP))
+			c.xwritecodeline(smtp.C421ServiceUnavail, smtp.SePol7Other0, "too many open connections from your ip or network", nil)
+			return
+		}
+		defer limiterConnections.Add(c.remoteIP, time.Now(), -1)
 	}
-	defer limiterConnections.Add(c.remoteIP, time.Now(), -1)
 
 	// We register and unregister the original connection, in case c.conn is replaced
 	// with a TLS connection later on.
@@ -1005,6 +1083,11 @@ func serve(listenerName string, cid int64, hostname dns.Domain, tlsConfig *tls.C
 	for {
 		command(c)
 
+		// For listing and revoking sessions of the account.
+		if c.account != nil {
+			mox.Connections.Activity(nc, "submission", c.account.Name, c.username, "")
+		}
+
 		// If another command is present, don't flush our buffered response yet. Holding
 		// off will cause us to respond with a single packet.
 		n := c.xbr.Buffered()
@@ -1208,6 +1291,9 @@ func (c *conn) cmdHello(p *parser, ehlo bool) {
 			// present, and also not indicate the server supports the PLUS variant in that
 			// case, or it would trigger the mechanism downgrade detection.
 			mechs = "SCRAM-SHA-256-PLUS SCRAM-SHA-256 SCRAM-SHA-1-PLUS SCRAM-SHA-1 CRAM-MD5 PLAIN LOGIN"
+			if oidc.Enabled() {
+				mechs += " OAUTHBEARER XOAUTH2"
+			}
 		}
 		if c.tls && len(c.conn.(*tls.Conn).ConnectionState().PeerCertificates) > 0 && !c.viaHTTPS {
 			mechs = "EXTERNAL " + mechs
@@ -1216,6 +1302,9 @@ func (c *conn) cmdHello(p *parser, ehlo bool) {
 		// ../rfc/4865:127
 		t := time.Now().Add(queue.FutureReleaseIntervalMax).UTC() // ../rfc/4865:98
 		c.xbwritelinef("250-FUTURERELEASE %d %s", queue.FutureReleaseIntervalMax/time.Second, t.Format(time.RFC3339))
+		// We only accept priorities from authenticated submitters, using the default
+		// MIXER policy. RFC 6710.
+		c.xbwritelinef("250-MT-PRIORITY")
 	}
 	c.xbwritelinef("250-ENHANCEDSTATUSCODES") // ../rfc/2034:71
 	// todo future? c.writelinef("250-DSN")
@@ -1312,6 +1401,25 @@ func (c *conn) cmdAuth(p *parser) {
 		}
 	}()
 
+	// Abort if logins for the account are locked after too many failed attempts, the
+	// password has expired, or an app password must be used.
+	xcheckAuthRefused := func(err error) {
+		if errors.Is(err, store.ErrLoginLocked) {
+			la.Result = store.AuthLoginLocked
+			c.log.Info("account login locked", slog.String("username", la.LoginAddress))
+			xsmtpUserErrorf(smtp.C454TempAuthFail, smtp.SePol7Other0, "%s", err)
+		} else if errors.Is(err, store.ErrPasswordExpired) {
+			la.Result = store.AuthPasswordExpired
+			c.log.Info("account password expired", slog.String("username", la.LoginAddress))
+			// ../rfc/4954:578
+			xsmtpUserErrorf(smtp.C432PasswdTransitionNeeded, smtp.SePol7PasswdTransitionReq12, "%s", err)
+		} else if errors.Is(err, store.ErrAppPassword) {
+			la.Result = store.AuthAppPasswordRequired
+			c.log.Info("account password used while app password required", slog.String("username", la.LoginAddress))
+			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "%s", err)
+		}
+	}
+
 	// ../rfc/4954:699
 	p.xspace()
 	mech := p.xsaslMech()
@@ -1409,13 +1517,14 @@ func (c *conn) cmdAuth(p *parser) {
 		}
 
 		var err error
-		account, la.AccountName, err = store.OpenEmailAuth(c.log, username, password, false)
+		account, la.AccountName, err = store.OpenEmailAuth(c.log, username, password, "submission", c.remoteIP, false)
 		if err != nil && errors.Is(err, store.ErrUnknownCredentials) {
 			// ../rfc/4954:274
 			la.Result = store.AuthBadCredentials
 			c.log.Info("failed authentication attempt", slog.String("username", username), slog.Any("remote", c.remoteIP))
 			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "bad user/pass")
 		}
+		xcheckAuthRefused(err)
 		xcheckf(err, "verifying credentials")
 
 	case "LOGIN":
@@ -1452,13 +1561,14 @@ func (c *conn) cmdAuth(p *parser) {
 		c.xtrace(mlog.LevelTrace) // Restore.
 
 		var err error
-		account, la.AccountName, err = store.OpenEmailAuth(c.log, username, password, false)
+		account, la.AccountName, err = store.OpenEmailAuth(c.log, username, password, "submission", c.remoteIP, false)
 		if err != nil && errors.Is(err, store.ErrUnknownCredentials) {
 			// ../rfc/4954:274
 			la.Result = store.AuthBadCredentials
 			c.log.Info("failed authentication attempt", slog.String("username", username), slog.Any("remote", c.remoteIP))
 			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "bad user/pass")
 		}
+		xcheckAuthRefused(err)
 		xcheckf(err, "verifying credentials")
 
 	case "CRAM-MD5":
@@ -1487,6 +1597,7 @@ func (c *conn) cmdAuth(p *parser) {
 		}
 		xcheckf(err, "looking up address")
 		la.AccountName = account.Name
+		xcheckAuthRefused(store.CheckLoginLockout(account.Name))
 		var ipadhash, opadhash hash.Hash
 		account.WithRLock(func() {
 			err := account.DB.Read(context.TODO(), func(tx *bstore.Tx) error {
@@ -1517,6 +1628,7 @@ func (c *conn) cmdAuth(p *parser) {
 		opadhash.Write(ipadhash.Sum(nil))
 		digest := fmt.Sprintf("%x", opadhash.Sum(nil))
 		if digest != t[1] {
+			la.Result = store.AuthBadCredentials
 			c.log.Info("failed authentication attempt", slog.String("username", username), slog.Any("remote", c.remoteIP))
 			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "bad user/pass")
 		}
@@ -1565,6 +1677,7 @@ func (c *conn) cmdAuth(p *parser) {
 			c.log.Info("failed authentication attempt", slog.String("username", username), slog.Any("remote", c.remoteIP))
 			xsmtpUserErrorf(smtp.C454TempAuthFail, smtp.SeSys3Other0, "scram not possible")
 		}
+		xcheckAuthRefused(store.CheckLoginLockout(account.Name))
 		if ss.Authorization != "" && ss.Authorization != username {
 			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "authentication with authorization for different user not supported")
 		}
@@ -1625,6 +1738,61 @@ func (c *conn) cmdAuth(p *parser) {
 		// The message should be empty. todo: should we require it is empty?
 		xreadContinuation()
 
+	case "OAUTHBEARER", "XOAUTH2":
+		la.AuthMech = strings.ToLower(mech)
+
+		if !oidc.Enabled() {
+			xsmtpUserErrorf(smtp.C504ParamNotImpl, smtp.SeProto5BadParams4, "mechanism %s not supported", mech)
+		}
+		// ../rfc/4954:343
+		if !c.tls && c.requireTLSForAuth {
+			xsmtpUserErrorf(smtp.C538EncReqForAuth, smtp.SePol7EncReqForAuth11, "authentication requires tls")
+		}
+
+		// Bearer tokens are credentials, mark as traceauth.
+		defer c.xtrace(mlog.LevelTraceauth)()
+		buf := xreadInitial("")
+		c.xtrace(mlog.LevelTrace) // Restore.
+		var authz, token string
+		var err error
+		if la.AuthMech == "oauthbearer" {
+			authz, token, err = oidc.ParseOAuthBearer(buf)
+		} else {
+			authz, token, err = oidc.ParseXOAUTH2(buf)
+		}
+		if err != nil {
+			la.Result = store.AuthBadProtocol
+			xsmtpUserErrorf(smtp.C501BadParamSyntax, smtp.SeProto5BadParams4, "parsing %s response: %s", mech, err)
+		}
+		username = norm.NFC.String(authz)
+		la.LoginAddress = username
+
+		address, err := oidc.TokenAddress(mox.Context, c.log, token)
+		if err == nil && username != "" && !strings.EqualFold(username, address) {
+			err = fmt.Errorf("%w: token is for address %q", oidc.ErrInvalidToken, address)
+		}
+		if err != nil && errors.Is(err, oidc.ErrInvalidToken) {
+			la.Result = store.AuthBadCredentials
+			c.log.Infox("failed bearer token authentication attempt", err, slog.String("username", username), slog.Any("remote", c.remoteIP))
+			// Error in a challenge, the client sends a dummy response, then we fail. RFC 7628
+			// section 3.2.3.
+			c.xwritelinef("%d %s", smtp.C334ContinueAuth, base64.StdEncoding.EncodeToString(oidc.ErrorChallenge()))
+			c.xreadline()
+			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "bad credentials")
+		}
+		xcheckf(err, "verifying bearer token")
+		if username == "" {
+			username = address
+			la.LoginAddress = username
+		}
+		account, la.AccountName, _, err = store.OpenEmail(c.log, username, false)
+		if err != nil && errors.Is(err, store.ErrUnknownCredentials) {
+			la.Result = store.AuthBadCredentials
+			c.log.Info("failed bearer token authentication attempt, no account for address", slog.String("username", username), slog.Any("remote", c.remoteIP))
+			xsmtpUserErrorf(smtp.C535AuthBadCreds, smtp.SePol7AuthBadCreds8, "bad credentials")
+		}
+		xcheckf(err, "looking up address")
+
 	case "EXTERNAL":
 		la.AuthMech = "external"
 
@@ -1661,6 +1829,12 @@ func (c *conn) cmdAuth(p *parser) {
 		la.Result = store.AuthLoginDisabled
 		c.log.Info("account login disabled", slog.String("username", username))
 		xsmtpUserErrorf(smtp.C525AccountDisabled, smtp.SePol7AccountDisabled13, "%w: %s", store.ErrLoginDisabled, accConf.LoginDisabled)
+	} else if la.AuthMech == "cram-md5" || strings.HasPrefix(la.AuthMech, "scram-") {
+		// Mechanisms that verified the account password without OpenEmailAuth.
+		if err := account.CheckPasswordLogin(); err != nil {
+			xcheckAuthRefused(err)
+			xcheckf(err, "checking password")
+		}
 	}
 
 	// We may already have TLS credentials. We allow an additional SASL authentication,
@@ -1833,6 +2007,21 @@ func (c *conn) cmdMail(p *parser) {
 				c.futureRelease = t
 				c.futureReleaseRequest = "until;" + s
 			}
+		case "MT-PRIORITY":
+			// Only for submission, we don't trust priorities from unauthenticated remote
+			// servers. RFC 6710.
+			if !c.submission {
+				xsmtpUserErrorf(smtp.C555UnrecognizedAddrParams, smtp.SeSys3NotSupported3, "unrecognized parameter %q", key)
+			}
+			p.xtake("=")
+			// Either "0", or a digit 1-9 with optional sign.
+			neg := p.take("-")
+			signed := neg || p.take("+")
+			v := int(p.xnumber(1, !signed))
+			if neg {
+				v = -v
+			}
+			c.mtPriority = v
 		default:
 			// ../rfc/5321:2230
 			xsmtpUserErrorf(smtp.C555UnrecognizedAddrParams, smtp.SeSys3NotSupported3, "unrecognized parameter %q", key)
@@ -2007,15 +2196,19 @@ func (c *conn) cmdRcpt(p *parser) {
 		if !c.submission {
 			xsmtpUserErrorf(smtp.C550MailboxUnavail, smtp.SeAddr1UnknownDestMailbox1, "not accepting email for ip")
 		}
-		c.recipients = append(c.recipients, recipient{fpath, nil, nil})
+		c.xcheckSuppressed(fpath)
+		c.recipients = append(c.recipients, recipient{fpath, nil, nil, false})
+	} else if !c.submission && mox.IsSpamtrap(fpath.Localpart, fpath.IPDomain.Domain) {
+		// Accepted like any other address, the message is not delivered.
+		c.recipients = append(c.recipients, recipient{fpath, nil, nil, true})
 	} else if accountName, alias, canonical, dest, err := mox.LookupAddress(fpath.Localpart, fpath.IPDomain.Domain, true, true, true); err == nil {
 		// note: a bare postmaster, without domain, is handled by LookupAddress. ../rfc/5321:735
 		if alias != nil {
-			c.recipients = append(c.recipients, recipient{fpath, nil, &rcptAlias{*alias, canonical}})
+			c.recipients = append(c.recipients, recipient{fpath, nil, &rcptAlias{*alias, canonical}, false})
 		} else if dest.SMTPError != "" {
 			xsmtpServerErrorf(codes{dest.SMTPErrorCode, dest.SMTPErrorSecode}, "%s", dest.SMTPErrorMsg)
 		} else {
-			c.recipients = append(c.recipients, recipient{fpath, &rcptAccount{accountName, dest, canonical}, nil})
+			c.recipients = append(c.recipients, recipient{fpath, &rcptAccount{accountName, dest, canonical}, nil, false})
 		}
 
 	} else if Localserve {
@@ -2025,7 +2218,7 @@ func (c *conn) cmdRcpt(p *parser) {
 		// which is typically the mox user.
 		acc, _ := mox.Conf.Account("mox")
 		dest := acc.Destinations["mox@localhost"]
-		c.recipients = append(c.recipients, recipient{fpath, &rcptAccount{"mox", dest, "mox@localhost"}, nil})
+		c.recipients = append(c.recipients, recipient{fpath, &rcptAccount{"mox", dest, "mox@localhost"}, nil, false})
 	} else if errors.Is(err, mox.ErrDomainDisabled) {
 		c.log.Info("smtp recipient for temporarily disabled domain", slog.Any("domain", fpath.IPDomain.Domain))
 		xsmtpUserErrorf(smtp.C450MailboxUnavail, smtp.SeMailbox2Disabled1, "recipient domain temporarily disabled")
@@ -2034,7 +2227,8 @@ func (c *conn) cmdRcpt(p *parser) {
 			xsmtpUserErrorf(smtp.C550MailboxUnavail, smtp.SeAddr1UnknownDestMailbox1, "not accepting email for domain")
 		}
 		// We'll be delivering this email.
-		c.recipients = append(c.recipients, recipient{fpath, nil, nil})
+		c.xcheckSuppressed(fpath)
+		c.recipients = append(c.recipients, recipient{fpath, nil, nil, false})
 	} else if errors.Is(err, mox.ErrAddressNotFound) {
 		if c.submission {
 			// For submission, we're transparent about which user exists. Should be fine for the typical small-scale deploy.
@@ -2044,7 +2238,7 @@ func (c *conn) cmdRcpt(p *parser) {
 		// We pretend to accept. We don't want to let remote know the user does not exist
 		// until after DATA. Because then remote has committed to sending a message.
 		// note: not local for !c.submission is the signal this address is in error.
-		c.recipients = append(c.recipients, recipient{fpath, nil, nil})
+		c.recipients = append(c.recipients, recipient{fpath, nil, nil, false})
 	} else {
 		c.log.Errorx("looking up account for delivery", err, slog.Any("rcptto", fpath))
 		xsmtpServerErrorf(codes{smtp.C451LocalErr, smtp.SeSys3Other0}, "error processing")
@@ -2052,6 +2246,20 @@ func (c *conn) cmdRcpt(p *parser) {
 	c.xbwritecodeline(smtp.C250Completed, smtp.SeAddr1Other0, "now on the list", nil)
 }
 
+// xcheckSuppressed rejects a recipient of a submission for remote delivery if it
+// is on the suppression list of the account. The queue would fail the delivery
+// anyway, this lets the client know immediately.
+func (c *conn) xcheckSuppressed(rcpt smtp.Path) {
+	sup, err := queue.SuppressionLookup(context.TODO(), c.account.Name, rcpt)
+	if err != nil {
+		c.log.Errorx("looking up suppression for recipient", err, slog.Any("rcptto", rcpt))
+		xsmtpServerErrorf(codes{smtp.C451LocalErr, smtp.SeSys3Other0}, "error processing")
+	} else if sup != nil {
+		c.log.Info("rejecting recipient on suppression list", slog.Any("rcptto", rcpt), slog.String("reason", sup.Reason))
+		xsmtpUserErrorf(smtp.C550MailboxUnavail, smtp.SePol7Other0, "recipient address on suppression list")
+	}
+}
+
 func hasNonASCII(s string) bool {
 	for _, c := range []byte(s) {
 		if c > unicode.MaxASCII {
@@ -2350,6 +2558,21 @@ func (c *conn) submit(ctx context.Context, recvHdrFor func(string) string, msgWr
 		xsmtpUserErrorf(smtp.C550MailboxUnavail, smtp.SePol7DeliveryUnauth1, "message from address must belong to authenticated user")
 	}
 
+	// Add configured footer to the message text, and apply configured changes to the
+	// header, before we look at the header further and add DKIM signatures.
+	if size, changed, err := c.account.ApplyOutgoingFooter(c.log, msgFrom, dataFile); err != nil {
+		xcheckf(err, "adding outgoing footer")
+	} else if changed {
+		msgWriter.Size = size
+	}
+	if size, changed, err := c.account.ApplyOutgoingHeaderRules(c.log, msgFrom, dataFile); err != nil {
+		xcheckf(err, "applying outgoing header rules")
+	} else if changed {
+		msgWriter.Size = size
+		_, _, header, err = message.From(c.log.Logger, true, dataFile, nil)
+		xcheckf(err, "parsing message header after applying outgoing header rules")
+	}
+
 	// TLS-Required: No header makes us not enforce recipient domain's TLS policy.
 	// ../rfc/8689:206
 	// Only when requiretls smtp extension wasn't used. ../rfc/8689:246
@@ -2398,6 +2621,21 @@ func (c *conn) submit(ctx context.Context, recvHdrFor func(string) string, msgWr
 	})
 	xcheckf(err, "read-only transaction")
 
+	// Check for anomalies in outgoing messages, a sign of a compromised account.
+	rcpts := make([]smtp.Path, len(c.recipients))
+	for i, r := range c.recipients {
+		rcpts[i] = r.Addr
+	}
+	err = webops.SendAnomalies(ctx, c.log, c.account, rcpts, c.remoteIP)
+	if errors.Is(err, webops.ErrSendSuspended) {
+		metricSubmission.WithLabelValues("suspended").Inc()
+		xsmtpUserErrorf(smtp.C451LocalErr, smtp.SePol7DeliveryUnauth1, "%s", err)
+	} else if errors.Is(err, webops.ErrSendThrottled) {
+		metricSubmission.WithLabelValues("throttled").Inc()
+		xsmtpUserErrorf(smtp.C451LocalErr, smtp.SePol7DeliveryUnauth1, "%s", err)
+	}
+	xcheckf(err, "checking for anomalies in outgoing messages")
+
 	// We gather any X-Mox-Extra-* headers into the "extra" data during queueing, which
 	// will make it into any webhook we deliver.
 	// todo: remove the X-Mox-Extra-* headers from the message. we don't currently rewrite the message...
@@ -2430,7 +2668,7 @@ func (c *conn) submit(ctx context.Context, recvHdrFor func(string) string, msgWr
 		xsmtpServerErrorf(codes{smtp.C451LocalErr, smtp.SeSys3Other0}, "domain of message from header is temporarily disabled")
 	}
 
-	selectors := mox.DKIMSelectors(confDom.DKIM)
+	selectors := mox.DKIMSelectorsMessage(c.log, confDom.DKIM, c.account.Name, msgFrom, store.FileMsgReader(msgPrefix, dataFile))
 	if len(selectors) > 0 {
 		canonical := mox.CanonicalLocalpart(msgFrom.Localpart, confDom)
 		if dkimHeaders, err := dkim.Sign(ctx, c.log.Logger, canonical, msgFrom.Domain, selectors, c.msgsmtputf8, store.FileMsgReader(msgPrefix, dataFile)); err != nil {
@@ -2518,6 +2756,7 @@ func (c *conn) submit(ctx context.Context, recvHdrFor func(string) string, msgWr
 			qm.NextAttempt = c.futureRelease
 			qm.FutureReleaseRequest = c.futureReleaseRequest
 		}
+		qm.Priority = c.mtPriority
 		qm.FromID = fromID
 		qm.Extra = extra
 		qml[i] = qm
@@ -2766,8 +3005,11 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 
 	// Give immediate response if all recipients are unknown.
 	nunknown := 0
+	nspamtraps := 0
 	for _, r := range c.recipients {
-		if r.Account == nil && r.Alias == nil {
+		if r.Spamtrap {
+			nspamtraps++
+		} else if r.Account == nil && r.Alias == nil {
 			nunknown++
 		}
 	}
@@ -2775,9 +3017,15 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		// During RCPT TO we found that the address does not exist.
 		c.log.Info("deliver attempt to unknown user(s)", slog.Any("recipients", c.recipients))
 
+		// Keep track of attempts to guess addresses in the server-wide reputation of the
+		// IP. The message From address has not been validated yet.
+		ipmasked1, _, _ := ipmasked(c.remoteIP)
+		err := reputationdb.AddDelivery(ctx, reputationdb.Delivery{IP: ipmasked1, UnknownRecipients: nunknown})
+		c.log.Check(err, "updating sender reputation for unknown recipients")
+
 		// Crude attempt to slow down someone trying to guess names. Would work better
 		// with connection rate limiter.
-		if unknownRecipientsDelay > 0 {
+		if unknownRecipientsDelay > 0 && !c.exempt.Delays {
 			mox.Sleep(ctx, unknownRecipientsDelay)
 		}
 
@@ -2920,6 +3168,8 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 	// have different policy override rules.
 	var dmarcMethod message.AuthMethod
 	var msgFromValidation = store.ValidationNone
+	// Set if DMARC failed but the message was forwarded by a trusted ARC sealer.
+	var arcSealer dns.Domain
 	if msgFrom.IsZero() {
 		dmarcResult.Status = dmarc.StatusNone
 		dmarcMethod = message.AuthMethod{
@@ -2962,10 +3212,98 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			msgFromValidation = store.ValidationDMARC
 		}
 
+		// Forwarders typically break DKIM signatures and SPF alignment. If the message
+		// was passed to us by a trusted intermediary that added a valid ARC set and
+		// recorded a DMARC pass, we don't apply the DMARC policy and consider the From
+		// address validated.
+		if dmarcResult.Status == dmarc.StatusFail && len(mox.Conf.Static.TrustedARCSealerDomains) > 0 {
+			arcctx, arccancel := context.WithTimeout(ctx, time.Minute)
+			defer arccancel()
+			arcSealer = arcTrustedSealer(arcctx, c.log, c.resolver, msgFrom.Domain, dataFile)
+			arccancel()
+			if !arcSealer.IsZero() {
+				c.log.Info("dmarc failure overridden by trusted arc sealer", slog.Any("sealer", arcSealer), slog.Any("fromdomain", msgFrom.Domain))
+				dmarcResult.Reject = false
+				msgFromValidation = store.ValidationDMARC
+			}
+		}
+
 		// todo future: consider enforcing an spf (soft)fail if there is no dmarc policy or the dmarc policy is none. ../rfc/7489:1507
 	}
 	c.log.Debug("dmarc verification", slog.Any("result", dmarcResult.Status), slog.Any("domain", msgFrom.Domain))
 
+	// BIMI, for showing the logo of the sender domain in mail clients. Only evaluated
+	// for messages that pass DMARC, and only with an enforced DMARC policy. The logo
+	// location is added as BIMI-Location header for recipients.
+	var bimiMethod *message.AuthMethod
+	var bimiLocation string
+	var bimiStatus string
+	var bimiDomain dns.Domain
+	if dmarcResult.Status == dmarc.StatusPass {
+		var selector string
+		if v := headers.Get("BIMI-Selector"); v != "" {
+			if sel, err := bimi.ParseSelector(v); err != nil {
+				c.log.Debugx("parsing bimi-selector header, using default selector", err, slog.String("header", v))
+			} else {
+				selector = sel
+			}
+		}
+		bimictx, bimicancel := context.WithTimeout(ctx, time.Minute)
+		bimiResult := bimi.Verify(bimictx, c.log.Logger, c.resolver, msgFrom.Domain, selector, dmarcResult)
+		bimicancel()
+		if bimiResult.Status != bimi.StatusSkipped {
+			bimiStatus, bimiDomain = string(bimiResult.Status), bimiResult.Domain
+			bimiMethod = &message.AuthMethod{
+				Method: "bimi",
+				Result: string(bimiResult.Status),
+				Props: []message.AuthProp{
+					message.MakeAuthProp("header", "d", bimiResult.Domain.ASCII, true, bimiResult.Domain.ASCIIExtra(c.msgsmtputf8)),
+					message.MakeAuthProp("header", "selector", bimiResult.Selector, false, ""),
+				},
+			}
+		}
+		if bimiResult.Status == bimi.StatusPass {
+			bimiLocation = "BIMI-Location: " + bimiResult.Record.String() + "\r\n"
+		}
+	}
+
+	// Authentication results, stored with the delivered messages. DMARC overrides and
+	// DNSBL results are added per recipient.
+	msgAuth := store.MessageAuth{
+		IPRev:          string(iprevStatus),
+		IPRevAuthentic: iprevAuthentic,
+		SPF:            string(receivedSPF.Result),
+		SPFAuthentic:   spfAuthentic,
+		DMARC:          string(dmarcResult.Status),
+		DMARCDomain:    msgFrom.Domain.Name(),
+		DMARCAuthentic: dmarcResult.RecordAuthentic,
+		ARCSealer:      arcSealer.Name(),
+		BIMI:           bimiStatus,
+		BIMIDomain:     bimiDomain.Name(),
+	}
+	if spfIdentity != nil {
+		msgAuth.SPFIdentity = string(receivedSPF.Identity)
+		msgAuth.SPFDomain = spfIdentity.Name()
+	}
+	if dmarcResult.Record != nil {
+		msgAuth.DMARCPolicy = string(dmarcResult.Record.Policy)
+	}
+	for _, r := range dkimResults {
+		ad := store.MessageAuthDKIM{Status: string(r.Status), Authentic: r.RecordAuthentic}
+		if r.Sig != nil {
+			ad.Domain = r.Sig.Domain.Name()
+			ad.Selector = r.Sig.Selector.Name()
+			ad.Algorithm = r.Sig.Algorithm()
+			if r.Sig.Identity != nil {
+				ad.Identity = r.Sig.Identity.String()
+			}
+		}
+		if r.Err != nil {
+			ad.Error = r.Err.Error()
+		}
+		msgAuth.DKIM = append(msgAuth.DKIM, ad)
+	}
+
 	// Prepare for analyzing content, calculating reputation.
 	ipmasked1, ipmasked2, ipmasked3 := ipmasked(c.remoteIP)
 	var verifiedDKIMDomains []string
@@ -3083,6 +3421,8 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			DSN:                isDSN,
 			Size:               msgWriter.Size,
 		}
+		auth := msgAuth
+		m.Auth = &auth
 		if c.tls {
 			tlsState := c.conn.(*tls.Conn).ConnectionState()
 			m.ReceivedTLSVersion = tlsState.Version
@@ -3099,9 +3439,11 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			msgTo = envelope.To
 			msgCc = envelope.CC
 		}
-		d := delivery{c.tls, &m, dataFile, smtpRcptTo, deliverTo, destination, canonicalAddr, acc, msgTo, msgCc, msgFrom, c.dnsBLs, dmarcUse, dmarcResult, dkimResults, iprevStatus, c.smtputf8}
+		d := delivery{c.tls, &m, dataFile, smtpRcptTo, deliverTo, destination, canonicalAddr, acc, msgTo, msgCc, msgFrom, c.dnsBLs, dmarcUse, dmarcResult, dkimResults, iprevStatus, c.smtputf8, c.tlsFingerprintListed(mox.Conf.Static.Listeners[c.listenerName].SMTP.TLSFingerprintsSuspicious), headers, c.exempt}
 
 		r := analyze(ctx, log, c.resolver, d)
+		r = screen(ctx, log, r)
+		r = categorize(log, r)
 		return &r, nil
 	}
 
@@ -3109,6 +3451,9 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 	// If recipient is an alias, we may be delivering to multiple address/accounts and
 	// we will consider a message delivered if we delivered it to at least one account
 	// (others may be over quota).
+	// We send at most one DMARC failure report per message.
+	var dmarcFailureReported bool
+
 	processRecipient := func(rcpt recipient) {
 		log := c.log.With(slog.Any("mailfrom", c.mailFrom), slog.Any("rcptto", rcpt.Addr))
 
@@ -3120,7 +3465,15 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		// deliveries, and return an error at the end? Though the failure conditions will
 		// probably prevent any other successful deliveries too...
 		// We'll continue delivering to other recipients. ../rfc/5321:3275
-		if rcpt.Account == nil && rcpt.Alias == nil {
+		if rcpt.Spamtrap {
+			// Not an error, remote must not learn the address is a spamtrap.
+			var senderDomain string
+			if msgFromValidation == store.ValidationStrict || msgFromValidation == store.ValidationDMARC || msgFromValidation == store.ValidationRelaxed {
+				senderDomain = publicsuffix.Lookup(ctx, log.Logger, msgFrom.Domain).Name()
+			}
+			spamtrapHit(ctx, log, rcpt.Addr, *c.mailFrom, isDSN, senderDomain, ipmasked1, dataFile, msgWriter.Size)
+			return
+		} else if rcpt.Account == nil && rcpt.Alias == nil {
 			metricDelivery.WithLabelValues("unknownuser", "").Inc()
 			addError(rcpt, smtp.C550MailboxUnavail, smtp.SeAddr1UnknownDestMailbox1, true, "no such user")
 			return
@@ -3140,18 +3493,55 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		// any recipient accepts it. Regular destination have just a single account to
 		// check. We check all alias destinations, even if we already explicitly delivered
 		// to them: they may be the only destination that would accept the message.
-		var a0 *analysis // Analysis we've used for accept/reject decision.
+		var a0 *analysis           // Analysis we've used for accept/reject decision.
+		var moderated bool         // Whether message to alias is delivered to its owners for moderation.
+		var noDelivery []smtp.Path // Alias member addresses not to deliver to, by preference.
+		if rcpt.Alias != nil && rcpt.Alias.Alias.List != nil {
+			// Email commands for mailing lists, e.g. list+subscribe@example.com, are
+			// handled by the list, not delivered to members.
+			d, _ := mox.Conf.Domain(rcpt.Alias.Alias.Domain)
+			if command := mlist.ParseCommand(d, rcpt.Alias.Alias, rcpt.Addr.Localpart); command != "" {
+				err := mlist.Command(ctx, log, rcpt.Alias.Alias, command, *c.mailFrom, msgFrom, dataFile)
+				if errors.Is(err, mlist.ErrUnknownCommand) || errors.Is(err, mlist.ErrUnknownToken) || errors.Is(err, mlist.ErrClosed) {
+					addError(rcpt, smtp.C550MailboxUnavail, smtp.SeAddr1UnknownDestMailbox1, true, err.Error())
+				} else if err != nil {
+					log.Errorx("processing list command", err, slog.String("command", command))
+					addError(rcpt, smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing")
+				} else {
+					metricDelivery.WithLabelValues("listcommand", "").Inc()
+				}
+				return
+			}
+		}
 		if rcpt.Alias != nil {
 			// Check if msgFrom address is acceptable. This doesn't take validation into
 			// consideration. If the header was forged, the message may be rejected later on.
-			if !aliasAllowedMsgFrom(rcpt.Alias.Alias, msgFrom) {
+			var aliasAddrs []config.AliasAddress
+			var ok bool
+			aliasAddrs, moderated, ok = aliasDeliveryAddresses(rcpt.Alias.Alias, msgFrom)
+			if !ok && rcpt.Alias.Alias.List != nil {
+				// Subscribers of mailing lists can post like members.
+				var err error
+				ok, err = mlist.IsSubscriber(ctx, rcpt.Alias.CanonicalAddress, msgFrom)
+				log.Check(err, "checking for list subscriber")
+			}
+			if !ok {
 				addError(rcpt, smtp.C550MailboxUnavail, smtp.SePol7ExpnProhibited2, true, "not allowed to send to destination")
 				return
 			}
 
-			la = make([]analysis, 0, len(rcpt.Alias.Alias.ParsedAddresses))
-			for _, aa := range rcpt.Alias.Alias.ParsedAddresses {
-				a, err := messageAnalyze(log, rcpt.Addr, aa.Address.Path(), aa.AccountName, aa.Destination, rcpt.Alias.CanonicalAddress)
+			la = make([]analysis, 0, len(aliasAddrs))
+			for _, aa := range aliasAddrs {
+				dest := aa.Destination
+				if !moderated {
+					if aa.Member.NoDelivery {
+						noDelivery = append(noDelivery, aa.Address.Path())
+					}
+					if aa.Member.Mailbox != "" {
+						dest.Mailbox = aa.Member.Mailbox
+					}
+				}
+				a, err := messageAnalyze(log, rcpt.Addr, aa.Address.Path(), aa.AccountName, dest, rcpt.Alias.CanonicalAddress)
 				if err != nil {
 					addError(rcpt, smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing")
 					return
@@ -3176,8 +3566,8 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			a0 = &la[0]
 		}
 
-		if !a0.accept && a0.reason == reasonHighRate {
-			log.Info("incoming message rejected for high rate, not storing in rejects mailbox", slog.String("reason", a0.reason), slog.Any("msgfrom", msgFrom))
+		if !a0.accept && (a0.reason == reasonHighRate || a0.reason == reasonSenderListReject) {
+			log.Info("incoming message rejected, not storing in rejects mailbox", slog.String("reason", a0.reason), slog.Any("msgfrom", msgFrom))
 			metricDelivery.WithLabelValues("reject", a0.reason).Inc()
 			c.setSlow(true)
 			addError(rcpt, a0.code, a0.secode, a0.userError, a0.errmsg)
@@ -3196,6 +3586,9 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		if dmarcResult.Record != nil && !dmarcUse {
 			dmarcOverrides = append(dmarcOverrides, string(dmarcrpt.PolicyOverrideSampledOut))
 		}
+		if !arcSealer.IsZero() {
+			dmarcOverrides = append(dmarcOverrides, string(dmarcrpt.PolicyOverrideTrustedForwarder))
+		}
 
 		// Add per-recipient DMARC method to Authentication-Results. Each account can have
 		// their own override rules, e.g. based on configured mailing lists/forwards.
@@ -3210,6 +3603,9 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		rcptAuthResults := authResults
 		rcptAuthResults.Methods = slices.Clone(authResults.Methods)
 		rcptAuthResults.Methods = append(rcptAuthResults.Methods, rcptDMARCMethod)
+		if bimiMethod != nil {
+			rcptAuthResults.Methods = append(rcptAuthResults.Methods, *bimiMethod)
+		}
 
 		// Prepend reason as message header, for easy viewing in mail clients.
 		var xmox string
@@ -3232,8 +3628,27 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			xmox = hw.String()
 		}
 		xmox += a0.headers
+		xmox += bimiLocation
+
+		if moderated {
+			xmox += "X-Mox-Moderation: Message to list " + rcpt.Alias.CanonicalAddress + " from non-owner, delivered to owners only, resend to the list to approve\r\n"
+		} else if rcpt.Alias != nil && rcpt.Alias.Alias.ListHeaders {
+			xmox += aliasListHeaders(rcpt.Alias.Alias, c.msgsmtputf8)
+		}
 
 		for i := range la {
+			la[i].d.m.Auth.DMARCOverrides = dmarcOverrides
+
+			// Explanation of the junk analysis, with the junk filter result if any.
+			if la[i].d.m.JunkVerdict == nil {
+				la[i].d.m.JunkVerdict = &store.MessageJunkVerdict{}
+			}
+			v := la[i].d.m.JunkVerdict
+			v.Accept = la[i].accept
+			v.Reason = la[i].reason
+			v.Details = la[i].reasonText
+			v.Mailbox = la[i].mailbox
+
 			// ../rfc/5321:3204
 			// Received-SPF header goes before Received. ../rfc/7208:2038
 			la[i].d.m.MsgPrefix = []byte(
@@ -3330,7 +3745,7 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 					Policy:          dmarcrpt.Disposition(r.Policy),
 					SubdomainPolicy: sp,
 					Percentage:      r.Percentage,
-					// We don't save ReportingOptions, we don't do per-message failure reporting.
+					// We don't save ReportingOptions, they are only used for failure reports.
 				},
 				SourceIP:        c.remoteIP.String(),
 				Disposition:     disposition,
@@ -3381,6 +3796,29 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			log.Check(err, "adding dmarc evaluation to database for aggregate report")
 		}
 
+		// Send a DMARC failure report if requested by the domain and enabled in our
+		// config. Like for aggregate reports, not for messages we reject as junk, and not
+		// for messages to our reporting addresses, to prevent reporting loops.
+		if !dmarcFailureReported && mox.Conf.Static.OutgoingDMARCFailureReports != nil && dmarcResult.Record != nil && len(dmarcResult.Record.FailureReportAddresses) > 0 && (a0.accept && !a0.d.m.IsReject || a0.reason == reasonDMARCPolicy) && !a0.d.destination.DMARCReports && !a0.d.destination.HostTLSReports && !a0.d.destination.DomainTLSReports {
+			dmarcFailureReported = true
+			authResultsValue := strings.TrimPrefix(rcptAuthResults.Header(), "Authentication-Results:")
+			authResultsValue = strings.TrimSpace(strings.ReplaceAll(authResultsValue, "\r\n\t", " "))
+			fm := dmarcdb.FailedMessage{
+				Result:      dmarcResult,
+				DKIMResults: dkimResults,
+				SPFResult:   receivedSPF.Result,
+				SourceIP:    c.remoteIP,
+				MailFrom:    *c.mailFrom,
+				RcptTo:      rcpt.Addr,
+				Arrival:     time.Now(),
+				Delivered:   a0.accept,
+				AuthResults: authResultsValue,
+				Msg:         dataFile,
+			}
+			err := dmarcdb.SendFailureReport(ctx, log, c.resolver, fm)
+			log.Check(err, "sending dmarc failure report")
+		}
+
 		if !a0.accept {
 			for _, a := range la {
 				// Don't add message if address was also explicitly present in a RCPT TO command.
@@ -3486,6 +3924,19 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 				log.Info("dmarc aggregate report processed")
 				a0.d.m.Flags.Seen = true
 				delayFirstTime = false
+				if d, err := dns.ParseDomain(a0.dmarcReport.PolicyPublished.Domain); err == nil {
+					err := dmarcdb.CheckFailureAlert(ctx, log, d, time.Now())
+					log.Check(err, "checking for dmarc failure spike")
+				}
+			}
+		}
+		if rcpt.Account != nil && a0.dmarcFailureReport != nil {
+			if err := dmarcdb.AddFailureReport(ctx, a0.dmarcFailureReport, msgFrom.Domain); err != nil {
+				log.Errorx("saving dmarc failure report in database", err)
+			} else {
+				log.Info("dmarc failure report processed")
+				a0.d.m.Flags.Seen = true
+				delayFirstTime = false
 			}
 		}
 		if rcpt.Account != nil && a0.tlsReport != nil {
@@ -3496,6 +3947,8 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 				log.Info("tlsrpt report processed")
 				a0.d.m.Flags.Seen = true
 				delayFirstTime = false
+				err := tlsrptdb.CheckNewFailureTypes(ctx, log, a0.tlsReport, time.Now())
+				log.Check(err, "checking for new tls failure types")
 			}
 		}
 
@@ -3544,6 +3997,16 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			if rcpt.Alias != nil && (regularRecipient(a.d.deliverTo) || a.d.deliverTo.Equal(msgFrom.Path())) {
 				continue
 			}
+			// Or to members that don't want messages from the alias.
+			if slices.ContainsFunc(noDelivery, a.d.deliverTo.Equal) {
+				continue
+			}
+			// Or for senders the account discards.
+			if a.reason == reasonSenderListDiscard {
+				log.Info("incoming message discarded due to sender lists", slog.Any("msgfrom", msgFrom))
+				metricDelivery.WithLabelValues("discard", a.reason).Inc()
+				continue
+			}
 
 			var delivered bool
 			a.d.acc.WithWLock(func() {
@@ -3563,8 +4026,9 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 				metricDelivery.WithLabelValues("delivered", a0.reason).Inc()
 				log.Info("incoming message delivered", slog.String("reason", a0.reason), slog.Any("msgfrom", msgFrom))
 
+				// Messages quarantined in the rejects mailbox must not be removed again.
 				conf, _ := a.d.acc.Conf()
-				if conf.RejectsMailbox != "" && a.d.m.MessageID != "" {
+				if conf.RejectsMailbox != "" && a.d.m.MessageID != "" && a.mailbox != conf.RejectsMailbox {
 					if err := a.d.acc.RejectsRemove(log, conf.RejectsMailbox, a.d.m.MessageID); err != nil {
 						log.Errorx("removing message from rejects mailbox", err, slog.String("messageid", messageID))
 					}
@@ -3580,6 +4044,12 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 				} else {
 					err = queue.Incoming(context.Background(), log, a.d.acc, messageID, *a.d.m, part, a.mailbox)
 					log.Check(err, "queueing webhook for incoming delivery")
+					err = caldav.Deliver(log, a.d.acc, *a.d.m, part)
+					log.Check(err, "processing calendar scheduling message")
+					err = applyRules(ctx, log, a.d.acc, *a.d.m, &part, a.d.deliverTo, c.maxMessageSize)
+					log.Check(err, "applying rules")
+					err = webpush.Notify(log, a.d.acc, a.d.m.ID, part)
+					log.Check(err, "sending web push notifications")
 				}
 			} else if nerr > 0 && ndelivered == 0 {
 				// Don't continue if we had an error and haven't delivered yet. If we only had
@@ -3593,6 +4063,14 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 			} else {
 				addError(rcpt, smtp.C451LocalErr, smtp.SeSys3Other0, false, "error processing")
 			}
+			return
+		}
+
+		// Send messages to mailing lists to the subscribers. Moderated messages are only
+		// sent after an owner resends them to the list.
+		if rcpt.Alias != nil && rcpt.Alias.Alias.List != nil && !moderated {
+			err := mlist.Distribute(ctx, log, c.resolver, rcpt.Alias.Alias, rcptAuthResults, dmarcRewriteFrom(dmarcResult, msgFrom), c.msgsmtputf8, msgFrom, dataFile)
+			log.Check(err, "sending message to list subscribers")
 		}
 	}
 
@@ -3601,6 +4079,30 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 		processRecipient(rcpt)
 	}
 
+	// Update the server-wide reputation of the sending domain and IP with the outcome
+	// of this delivery attempt.
+	repDelivery := reputationdb.Delivery{
+		DomainValidated: msgFromValidation == store.ValidationStrict || msgFromValidation == store.ValidationDMARC || msgFromValidation == store.ValidationRelaxed,
+		IP:              ipmasked1,
+		SPFPass:         receivedSPF.Result == spf.StatusPass,
+		DKIMPass:        len(verifiedDKIMDomains) > 0,
+		DMARCPass:       dmarcResult.Status == dmarc.StatusPass,
+		DMARCFail:       dmarcResult.Status == dmarc.StatusFail,
+		Accepted:        len(deliverErrors)+nspamtraps < len(c.recipients),
+	}
+	if !msgFrom.IsZero() {
+		repDelivery.Domain = publicsuffix.Lookup(ctx, c.log.Logger, msgFrom.Domain).Name()
+	}
+	for _, e := range deliverErrors {
+		if e.secode == smtp.SeAddr1UnknownDestMailbox1 {
+			repDelivery.UnknownRecipients++
+		} else if e.userError {
+			repDelivery.Rejected = true
+		}
+	}
+	err = reputationdb.AddDelivery(ctx, repDelivery)
+	c.log.Check(err, "updating sender reputation for delivery")
+
 	// If all recipients failed to deliver, return an error.
 	if len(c.recipients) == len(deliverErrors) {
 		same := true
@@ -3692,19 +4194,71 @@ func (c *conn) deliver(ctx context.Context, recvHdrFor func(string) string, msgW
 	c.xwritecodeline(smtp.C250Completed, smtp.SeMailbox2Other0, "it is done", nil)
 }
 
-// Return whether msgFrom address is allowed to send a message to alias.
-func aliasAllowedMsgFrom(alias config.Alias, msgFrom smtp.Address) bool {
-	for _, aa := range alias.ParsedAddresses {
-		if aa.Address == msgFrom {
-			return true
+// aliasDeliveryAddresses returns the addresses to deliver a message with msgFrom
+// to the alias to. Usually the members, but for moderated aliases, messages from
+// others than the owners are only delivered to the owners, with moderated set. If
+// msgFrom is not allowed to send to the alias, ok is false.
+func aliasDeliveryAddresses(alias config.Alias, msgFrom smtp.Address) (addrs []config.AliasAddress, moderated, ok bool) {
+	isAddr := func(l []config.AliasAddress) bool {
+		return slices.ContainsFunc(l, func(aa config.AliasAddress) bool { return aa.Address == msgFrom })
+	}
+	lp, err := smtp.ParseLocalpart(alias.LocalpartStr)
+	xcheckf(err, "parsing alias localpart")
+	isAlias := msgFrom == smtp.NewAddress(lp, alias.Domain)
+
+	switch alias.EffectivePostPolicy() {
+	case config.AliasPostAnyone:
+		return alias.ParsedAddresses, false, !isAlias || alias.AllowMsgFrom
+	case config.AliasPostModerated:
+		if isAddr(alias.ParsedOwners) || isAlias && alias.AllowMsgFrom {
+			return alias.ParsedAddresses, false, true
 		}
+		return alias.ParsedOwners, true, true
+	}
+	if isAddr(alias.ParsedAddresses) || isAddr(alias.ParsedOwners) {
+		return alias.ParsedAddresses, false, true
 	}
+	return alias.ParsedAddresses, false, isAlias && alias.AllowMsgFrom
+}
+
+// dmarcRewriteFrom returns whether the DMARC policy for the domain of the message
+// From address would cause recipients to reject or quarantine the message when
+// sent from another domain, such as by a mailing list.
+func dmarcRewriteFrom(result dmarc.Result, msgFrom smtp.Address) bool {
+	r := result.Record
+	if r == nil {
+		return false
+	}
+	policy := r.Policy
+	if result.Domain != msgFrom.Domain && r.SubdomainPolicy != dmarc.PolicyEmpty {
+		policy = r.SubdomainPolicy
+	}
+	return policy == dmarc.PolicyReject || policy == dmarc.PolicyQuarantine
+}
+
+// aliasListHeaders returns List-Id, List-Post and, if the account web interface
+// is available over HTTPS, List-Unsubscribe message headers for messages delivered
+// through the alias. ../rfc/2919:174 ../rfc/2369:167
+func aliasListHeaders(alias config.Alias, smtputf8 bool) string {
 	lp, err := smtp.ParseLocalpart(alias.LocalpartStr)
 	xcheckf(err, "parsing alias localpart")
-	if msgFrom == smtp.NewAddress(lp, alias.Domain) {
-		return alias.AllowMsgFrom
+	addr := smtp.NewAddress(lp, alias.Domain)
+
+	// The list label must be a dot-atom, we replace other characters.
+	label := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' {
+			return r
+		}
+		return '-'
+	}, alias.LocalpartStr)
+	h := "List-Id: <" + label + "." + alias.Domain.ASCII + ">\r\n"
+	h += "List-Post: <mailto:" + addr.Path().XString(smtputf8) + ">\r\n"
+
+	// Members leave the list through the account web interface.
+	if u := mox.AccountURL(); u != "" {
+		h += "List-Unsubscribe: <" + u + ">\r\n"
 	}
-	return alias.PostPublic
+	return h
 }
 
 // ecode returns either ecode, or a more specific error based on err.
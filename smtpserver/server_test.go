@@ -4,6 +4,7 @@ package smtpserver
 // todo: test delivering a message to multiple recipients, and with some of them failing.
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto/ed25519"
@@ -17,6 +18,8 @@ import (
 	"math/big"
 	"mime/quotedprintable"
 	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"sort"
@@ -27,18 +30,23 @@ import (
 	"github.com/mjl-/bstore"
 
 	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/contentrep"
 	"github.com/mjl-/mox/dkim"
 	"github.com/mjl-/mox/dmarcdb"
 	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/junk"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/sasl"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/smtpclient"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/subjectpass"
+	"github.com/mjl-/mox/tlsfp"
 	"github.com/mjl-/mox/tlsrptdb"
+	"github.com/mjl-/mox/webapi"
 	"github.com/mjl-/mox/webops"
 )
 
@@ -152,6 +160,8 @@ func newTestServer(t *testing.T, configPath string, resolver dns.Resolver) *test
 	tcheck(t, err, "dmarcdb init")
 	err = tlsrptdb.Init()
 	tcheck(t, err, "tlsrptdb init")
+	err = reputationdb.Init()
+	tcheck(t, err, "reputationdb init")
 	err = store.Init(ctxbg)
 	tcheck(t, err, "store init")
 
@@ -177,6 +187,8 @@ func (ts *testserver) close() {
 	tcheck(ts.t, err, "dmarcdb close")
 	err = tlsrptdb.Close()
 	tcheck(ts.t, err, "tlsrptdb close")
+	err = reputationdb.Close()
+	tcheck(ts.t, err, "reputationdb close")
 	ts.comm.Unregister()
 	queue.Shutdown()
 	err = ts.acc.Close()
@@ -399,6 +411,15 @@ func TestSubmission(t *testing.T) {
 		testAuth(fn, "disabled@mox.example", "bogus", &smtpclient.Error{Code: smtp.C535AuthBadCreds, Secode: smtp.SePol7AuthBadCreds8})
 	}
 
+	// Recipients on the suppression list of the account are rejected.
+	rcptPath := smtp.Path{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "example.org"}}}
+	err = queue.SuppressionAdd(ctxbg, rcptPath, &webapi.Suppression{Account: "mjl", Manual: true, Reason: "test"})
+	tcheck(t, err, "add suppression")
+	testAuth(authfns[0], "mjl@mox.example", password0, &smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SePol7Other0})
+	err = queue.SuppressionRemove(ctxbg, "mjl", rcptPath)
+	tcheck(t, err, "remove suppression")
+	testAuth(authfns[0], "mjl@mox.example", password0, nil)
+
 	// Create a certificate, register its public key with account, and make a tls
 	// client config that sends the certificate.
 	clientCert0 := fakeCert(ts.t, true)
@@ -802,6 +823,558 @@ func TestSpam(t *testing.T) {
 	})
 }
 
+// Test tiered spam actions based on the spaminess score of the junk filter.
+func TestSpamActions(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx and iprev check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/junk/mox.conf"), resolver)
+	defer ts.close()
+
+	// Train the junk filter with messages from another sender, so the test sender has
+	// no reputation and the content is classified. Enough hams for a significant
+	// score.
+	m := store.Message{
+		RemoteIP:          "10.0.0.1",
+		RemoteIPMasked1:   "10.0.0.1",
+		RemoteIPMasked2:   "10.0.0.0",
+		RemoteIPMasked3:   "10.0.0.0",
+		MailFrom:          "other@example.net",
+		MailFromLocalpart: smtp.Localpart("other"),
+		MailFromDomain:    "example.net",
+		MsgFromLocalpart:  smtp.Localpart("other"),
+		MsgFromDomain:     "example.net",
+		MsgFromOrgDomain:  "example.net",
+	}
+	hamMsg := strings.ReplaceAll("From: <other@example.net>\nTo: <mjl@mox.example>\nSubject: hi\nContent-Type: text/plain\n\nmeeting agenda tomorrow\n", "\n", "\r\n")
+	spamMsg := strings.ReplaceAll("From: <other@example.net>\nTo: <mjl@mox.example>\nSubject: hi\nContent-Type: text/plain\n\ncheap pills discount offer\n", "\n", "\r\n")
+	for range 50 {
+		nm := m
+		nm.Flags = store.Flags{Seen: true, Notjunk: true}
+		nm.Size = int64(len(hamMsg))
+		tinsertmsg(t, ts.acc, "Inbox", &nm, hamMsg)
+	}
+	for range 3 {
+		nm := m
+		nm.Flags = store.Flags{Seen: true, Junk: true}
+		nm.Size = int64(len(spamMsg))
+		tinsertmsg(t, ts.acc, "Inbox", &nm, spamMsg)
+	}
+	tretrain(t, ts.acc)
+
+	// The spammy messages below score 0.9986, the others 0.7 at most. The threshold
+	// gets up to 0.05 of jitter, so lower it to keep the outcomes deterministic.
+	setSpamActions := func(sa config.SpamActions) {
+		acc := mox.Conf.Dynamic.Accounts[ts.acc.Name]
+		jf := *acc.JunkFilter
+		jf.Threshold = 0.9
+		jf.SpamActions = sa
+		acc.JunkFilter = &jf
+		mox.Conf.Dynamic.Accounts[ts.acc.Name] = acc
+	}
+	setSpamActions(config.SpamActions{})
+
+	deliver := func(msgID, text string, expErr *smtpclient.Error) store.Message {
+		t.Helper()
+		msg := strings.ReplaceAll(fmt.Sprintf("From: <remote@example.org>\nTo: <mjl@mox.example>\nSubject: test\nMessage-Id: <%s@example.org>\nContent-Type: text/plain\n\n%s\n", msgID, text), "\n", "\r\n")
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+		m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+		tcheck(t, err, "get last message")
+		return m
+	}
+
+	// Spammy message is rejected by default.
+	deliver("test1", "cheap pills discount offer", &smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
+	ts.checkCount("Rejects", 1)
+
+	// With the quarantine action, it is accepted, but only stored in the rejects
+	// mailbox. With tagging, it gets spam headers.
+	setSpamActions(config.SpamActions{TagThreshold: 0.5, HighAction: config.SpamHighActionQuarantine})
+	m = deliver("test2", "cheap pills discount offer", nil)
+	ts.checkCount("Rejects", 2)
+	tcompare(t, m.IsReject, true)
+	tcompare(t, m.JunkVerdict.Reason, "junk-quarantine")
+	tcompare(t, strings.Contains(string(m.MsgPrefix), "X-Mox-Spam-Flag: YES\r\n"), true)
+
+	// Moderately spammy message is delivered to Junk with the junk threshold.
+	setSpamActions(config.SpamActions{JunkThreshold: 0.2})
+	m = deliver("test3", "meeting agenda cheap pills", nil)
+	ts.checkCount("Junk", 1)
+	tcompare(t, m.JunkVerdict.Reason, "junk-score")
+	tcompare(t, strings.Contains(string(m.MsgPrefix), "X-Mox-Spam-Flag"), false)
+
+	// Message below the junk threshold is delivered normally. Note: the previous
+	// message in Junk was trained as spam.
+	setSpamActions(config.SpamActions{JunkThreshold: 0.8})
+	m = deliver("test4", "meeting agenda tomorrow", nil)
+	tcompare(t, m.JunkVerdict.Reason, "no-bad-signals")
+}
+
+// Test overrides in the server-wide sender reputation.
+func TestSenderReputation(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.1"}, // For mx check.
+		},
+		TXT: map[string][]string{
+			"example.org.":        {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.": {"v=DMARC1;p=reject"},
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/junk/mox.conf"), resolver)
+	defer ts.close()
+
+	// Sender with bad reputation in the account.
+	m := store.Message{
+		RemoteIP:          "127.0.0.10",
+		RemoteIPMasked1:   "127.0.0.10",
+		RemoteIPMasked2:   "127.0.0.0",
+		RemoteIPMasked3:   "127.0.0.0",
+		MailFrom:          "remote@example.org",
+		MailFromLocalpart: smtp.Localpart("remote"),
+		MailFromDomain:    "example.org",
+		RcptToLocalpart:   smtp.Localpart("mjl"),
+		RcptToDomain:      "mox.example",
+		MsgFromLocalpart:  smtp.Localpart("remote"),
+		MsgFromDomain:     "example.org",
+		MsgFromOrgDomain:  "example.org",
+		MsgFromValidated:  true,
+		MsgFromValidation: store.ValidationStrict,
+		Flags:             store.Flags{Seen: true, Junk: true},
+		Size:              int64(len(deliverMessage)),
+	}
+	for range 3 {
+		nm := m
+		tinsertmsg(t, ts.acc, "Inbox", &nm, deliverMessage)
+	}
+
+	deliver := func(expErr *smtpclient.Error) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+
+	deliver(&smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
+	s, err := reputationdb.Get(ctxbg, reputationdb.SenderDomain, "example.org")
+	tcheck(t, err, "get sender reputation")
+	tcompare(t, []int64{s.Accepted, s.Rejected, s.DMARCPass}, []int64{0, 1, 1})
+
+	// Admin override to always allow takes precedence over the reputation in the account.
+	err = reputationdb.SetOverride(ctxbg, reputationdb.SenderDomain, "example.org", reputationdb.OverrideAllow)
+	tcheck(t, err, "set override")
+	deliver(nil)
+	ts.checkCount("Inbox", 4)
+
+	// Quarantine delivers to the junk mailbox.
+	err = reputationdb.SetOverride(ctxbg, reputationdb.SenderIP, "127.0.0.10", reputationdb.OverrideQuarantine)
+	tcheck(t, err, "set override")
+	err = reputationdb.SetOverride(ctxbg, reputationdb.SenderDomain, "example.org", reputationdb.OverrideNone)
+	tcheck(t, err, "clear override")
+	deliver(nil)
+	ts.checkCount("Inbox", 4)
+	ts.checkCount("Junk", 1)
+}
+
+// Test screening of first-time senders.
+func TestScreening(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx and iprev check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+		TXT: map[string][]string{
+			"example.org.":        {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.": {"v=DMARC1;p=reject"},
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	acc := mox.Conf.Dynamic.Accounts[ts.acc.Name]
+	acc.Screening = &config.Screening{}
+	mox.Conf.Dynamic.Accounts[ts.acc.Name] = acc
+
+	deliver := func(msg string, expErr *smtpclient.Error) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+
+	// First-time sender is delivered to the screening mailbox.
+	deliver(deliverMessage, nil)
+	ts.checkCount("Screening", 1)
+	ts.checkCount("Inbox", 0)
+
+	// Still unknown, messages in the screening mailbox don't count.
+	deliver(deliverMessage2, nil)
+	ts.checkCount("Screening", 2)
+
+	// Once allowed, delivered to Inbox.
+	_, err := ts.acc.SenderListSave(pkglog, "Remote@example.org", store.SenderListAllow)
+	tcheck(t, err, "allow sender")
+	deliver(deliverMessage, nil)
+	ts.checkCount("Inbox", 1)
+	ts.checkCount("Screening", 2)
+}
+
+// Test the sender allow and block lists of an account.
+func TestSenderList(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.1"}, // For mx check.
+		},
+		TXT: map[string][]string{
+			"example.org.":        {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.": {"v=DMARC1;p=reject"},
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/junk/mox.conf"), resolver)
+	defer ts.close()
+
+	// Sender with bad reputation in the account.
+	m := store.Message{
+		RemoteIP:          "127.0.0.10",
+		RemoteIPMasked1:   "127.0.0.10",
+		RemoteIPMasked2:   "127.0.0.0",
+		RemoteIPMasked3:   "127.0.0.0",
+		MailFrom:          "remote@example.org",
+		MailFromLocalpart: smtp.Localpart("remote"),
+		MailFromDomain:    "example.org",
+		RcptToLocalpart:   smtp.Localpart("mjl"),
+		RcptToDomain:      "mox.example",
+		MsgFromLocalpart:  smtp.Localpart("remote"),
+		MsgFromDomain:     "example.org",
+		MsgFromOrgDomain:  "example.org",
+		MsgFromValidated:  true,
+		MsgFromValidation: store.ValidationStrict,
+		Flags:             store.Flags{Seen: true, Junk: true},
+		Size:              int64(len(deliverMessage)),
+	}
+	for range 3 {
+		nm := m
+		tinsertmsg(t, ts.acc, "Inbox", &nm, deliverMessage)
+	}
+
+	deliver := func(expErr *smtpclient.Error) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+
+	deliver(&smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
+
+	// Allowed domain bypasses the junk checks.
+	allow, err := ts.acc.SenderListSave(pkglog, "example.org", store.SenderListAllow)
+	tcheck(t, err, "allow domain")
+	deliver(nil)
+	ts.checkCount("Inbox", 4)
+
+	// Address takes precedence over domain.
+	_, err = ts.acc.SenderListSave(pkglog, "remote@example.org", store.SenderListReject)
+	tcheck(t, err, "reject address")
+	deliver(&smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SePol7DeliveryUnauth1})
+
+	// Discarded messages are accepted, but not delivered.
+	_, err = ts.acc.SenderListSave(pkglog, "remote@example.org", store.SenderListDiscard)
+	tcheck(t, err, "discard address")
+	deliver(nil)
+	ts.checkCount("Inbox", 4)
+
+	// Wildcard domain matches the domain itself, the address takes precedence.
+	err = ts.acc.SenderListRemove(pkglog, allow.ID)
+	tcheck(t, err, "remove allow")
+	_, err = ts.acc.SenderListSave(pkglog, "*.example.org", store.SenderListReject)
+	tcheck(t, err, "reject wildcard")
+	e, err := ts.acc.SenderListSave(pkglog, "remote@example.org", store.SenderListAllow)
+	tcheck(t, err, "allow address")
+	deliver(nil)
+	ts.checkCount("Inbox", 5)
+	err = ts.acc.SenderListRemove(pkglog, e.ID)
+	tcheck(t, err, "remove address")
+	deliver(&smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SePol7DeliveryUnauth1})
+}
+
+// Test categorizing bulk messages into mailboxes and keywords.
+func TestCategories(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx and iprev check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+		TXT: map[string][]string{
+			"example.org.":        {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.": {"v=DMARC1;p=reject"},
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	acc := mox.Conf.Dynamic.Accounts[ts.acc.Name]
+	acc.Categories = &config.Categories{
+		Newsletters:   &config.Category{Mailbox: "Newsletters"},
+		Notifications: &config.Category{},
+	}
+	mox.Conf.Dynamic.Accounts[ts.acc.Name] = acc
+
+	deliver := func(msg string, expKeywords []string) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			tcheck(t, err, "deliver")
+		})
+		m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).SortDesc("ID").Limit(1).Get()
+		tcheck(t, err, "get delivered message")
+		tcompare(t, m.Keywords, expKeywords)
+	}
+
+	// Regular message is not categorized.
+	deliver(deliverMessage, nil)
+	ts.checkCount("Inbox", 1)
+
+	// Newsletter to its mailbox.
+	deliver("List-Unsubscribe: <https://example.org/unsubscribe>\r\n"+deliverMessage2, []string{"$newsletter"})
+	ts.checkCount("Newsletters", 1)
+	ts.checkCount("Inbox", 1)
+
+	// Notification is only marked with a keyword.
+	deliver("Auto-Submitted: auto-generated\r\n"+deliverMessage2, []string{"$notification"})
+	ts.checkCount("Inbox", 2)
+}
+
+// Test messages to spamtraps are not delivered, but update the sender reputation
+// and train the shared junk filter.
+func TestSpamtraps(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx and iprev check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+		TXT: map[string][]string{
+			"example.org.":        {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.": {"v=DMARC1;p=reject"},
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	dom := mox.Conf.Dynamic.Domains["mox.example"]
+	dom.Spamtraps = []string{"trap"}
+	dom.SpamtrapLocalparts = []smtp.Localpart{"trap"}
+	dom.SharedJunkFilter = &config.SharedJunkFilter{Weight: 0.5, Params: junk.Params{Onegrams: true, MaxPower: 0.1, TopWords: 10}}
+	mox.Conf.Dynamic.Domains["mox.example"] = dom
+
+	deliver := func(mailFrom string, rcptTo ...string) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			_, err := client.DeliverMultiple(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+			tcheck(t, err, "deliver")
+		})
+	}
+	checkSpams := func(exp uint32) {
+		t.Helper()
+		err := store.WithSharedJunkFilter(ctxbg, pkglog, dom.Domain, func(jf *junk.Filter, _ config.SharedJunkFilter) error {
+			stats, err := jf.Stats(ctxbg)
+			tcompare(t, stats.Spams, exp)
+			return err
+		})
+		tcheck(t, err, "shared junk filter stats")
+	}
+
+	// Accepted, but not delivered. Case-insensitive like other addresses.
+	deliver("remote@example.org", "Trap@mox.example")
+	ts.checkCount("Inbox", 0)
+	s, err := reputationdb.Get(ctxbg, reputationdb.SenderDomain, "example.org")
+	tcheck(t, err, "get sender reputation")
+	tcompare(t, s.Spamtraps, int64(1))
+	s, err = reputationdb.Get(ctxbg, reputationdb.SenderIP, "127.0.0.10")
+	tcheck(t, err, "get sender reputation")
+	tcompare(t, []int64{s.Spamtraps, s.Accepted}, []int64{1, 0})
+	checkSpams(1)
+
+	// Regular recipients still get the message.
+	deliver("remote@example.org", "trap@mox.example", "mjl@mox.example")
+	ts.checkCount("Inbox", 1)
+	checkSpams(2)
+
+	// Bounces are ignored, they're likely backscatter.
+	deliver("", "trap@mox.example")
+	checkSpams(2)
+
+	// Enough spamtrap hits give the sender a junk reputation.
+	deliver("remote@example.org", "trap@mox.example")
+	ev, err := reputationdb.Evaluate(ctxbg, "example.org", "127.0.0.10")
+	tcheck(t, err, "evaluate reputation")
+	tcompare(t, ev.Junk != nil && *ev.Junk, true)
+
+	// Not trained for senders with messages marked as not junk, against poisoning.
+	reputationdb.JunkMarked(ctxbg, pkglog, store.Message{MsgFromValidated: true, MsgFromOrgDomain: "example.org"}, false)
+	deliver("remote@example.org", "trap@mox.example")
+	checkSpams(3)
+	s, err = reputationdb.Get(ctxbg, reputationdb.SenderDomain, "example.org")
+	tcheck(t, err, "get sender reputation")
+	tcompare(t, s.Spamtraps, int64(4))
+}
+
+// Test checking messages with an external spam filter.
+func TestSpamFilter(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx and iprev check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/junk/mox.conf"), resolver)
+	defer ts.close()
+
+	var response string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, response)
+	}))
+	defer srv.Close()
+	mox.Conf.Static.SpamFilter = &config.SpamFilter{Rspamd: srv.URL, RejectScore: 20, QuarantineScore: 10}
+	defer func() {
+		mox.Conf.Static.SpamFilter = nil
+	}()
+
+	deliver := func(msg string, expErr *smtpclient.Error) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+	rejectErr := &smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0}
+
+	// Spam verdict of the external filter, junk filter of account has no opinion.
+	response = `{"score": 6, "required_score": 15, "action": "add header", "symbols": {"BAYES_SPAM": {"name": "BAYES_SPAM"}}}`
+	deliver(deliverMessage, rejectErr)
+	ts.checkCount("Rejects", 1)
+
+	// Score above reject score.
+	response = `{"score": 25, "required_score": 15, "action": "reject"}`
+	deliver(deliverMessage2, rejectErr)
+	ts.checkCount("Rejects", 2)
+
+	// Score above quarantine score.
+	response = `{"score": 12, "required_score": 15, "action": "add header"}`
+	deliver(deliverMessage, nil)
+	ts.checkCount("Junk", 1)
+
+	// Remove the quarantined message, so it does not influence reputation.
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get quarantined message")
+	// The junk verdict is stored with the message.
+	tcompare(t, m.JunkVerdict != nil, true)
+	tcompare(t, m.JunkVerdict.Accept, true)
+	tcompare(t, m.JunkVerdict.Reason, "spam-filter-quarantine")
+	tcompare(t, m.JunkVerdict.Mailbox, "Junk")
+	tcompare(t, m.JunkVerdict.Classified, true)
+	tcompare(t, len(m.JunkVerdict.Details) > 0, true)
+	ts.xops.MessageDelete(ctxbg, pkglog, ts.acc, []int64{m.ID})
+
+	// Ham verdict, X-Spam headers are added.
+	response = `{"score": 1.5, "required_score": 15, "action": "no action", "symbols": {"R_SPF_NA": {"name": "R_SPF_NA"}}}`
+	deliver(deliverMessage, nil)
+	ts.checkCount("Inbox", 1)
+	m, err = bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get delivered message")
+	if !strings.Contains(string(m.MsgPrefix), "X-Spam-Status: No, score=1.50 required=15.00 filter=rspamd\r\n") || !strings.Contains(string(m.MsgPrefix), "X-Spam-Symbols: R_SPF_NA\r\n") {
+		t.Fatalf("missing x-spam headers in message prefix %q", m.MsgPrefix)
+	}
+}
+
+// Test checking URLs in messages against local lists and URI block lists.
+func TestContentReputation(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.":               {"127.0.0.10"}, // For mx and iprev check.
+			"listed.example.uribl.test.": {"127.0.0.2"},
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/junk/mox.conf"), resolver)
+	defer ts.close()
+
+	contentrep.ResetCache()
+	mox.Conf.Static.ContentReputation = &config.ContentReputation{
+		URIBLZones:           []dns.Domain{{ASCII: "uribl.test"}},
+		BlockedDomainsParsed: []dns.Domain{{ASCII: "blocked.example"}},
+	}
+	defer func() {
+		mox.Conf.Static.ContentReputation = nil
+	}()
+
+	deliver := func(msgID, url string, expErr *smtpclient.Error) {
+		t.Helper()
+		msg := strings.ReplaceAll(fmt.Sprintf("From: <remote@example.org>\nTo: <mjl@mox.example>\nSubject: test\nMessage-Id: <%s@example.org>\n\nsee %s\n", msgID, url), "\n", "\r\n")
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@example.org"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+	rejectErr := &smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0}
+
+	// Subdomain of locally blocked domain.
+	deliver("test1", "https://www.blocked.example/offer", rejectErr)
+	ts.checkCount("Rejects", 1)
+
+	// Organizational domain listed in URI block list.
+	deliver("test2", "http://www.listed.example/", rejectErr)
+	ts.checkCount("Rejects", 2)
+
+	// With quarantine, delivered to Junk.
+	mox.Conf.Static.ContentReputation.Quarantine = true
+	deliver("test3", "https://blocked.example", nil)
+	ts.checkCount("Junk", 1)
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get quarantined message")
+	tcompare(t, m.JunkVerdict != nil, true)
+	tcompare(t, m.JunkVerdict.Reason, "content-reputation-quarantine")
+	ts.xops.MessageDelete(ctxbg, pkglog, ts.acc, []int64{m.ID})
+
+	// Not listed.
+	deliver("test4", "https://www.other.example/", nil)
+	ts.checkCount("Inbox", 1)
+}
+
 // Test accept/reject with forwarded messages, DMARC ignored, no IP/EHLO/MAIL
 // FROM-based reputation.
 func TestForward(t *testing.T) {
@@ -1008,7 +1581,9 @@ func TestDMARCSent(t *testing.T) {
 	})
 
 	// We should now be accepting the message because we recently sent a message.
+	// With DMARC pass and an enforced policy, the BIMI logo location is added.
 	resolver.TXT["example.org."] = []string{"v=spf1 ip4:127.0.0.10 -all"}
+	resolver.TXT["default._bimi.example.org."] = []string{"v=BIMI1; l=https://example.org/logo.svg"}
 	ts.run(func(client *smtpclient.Client) {
 		mailFrom := "remote@example.org"
 		rcptTo := "mjl@mox.example"
@@ -1017,6 +1592,88 @@ func TestDMARCSent(t *testing.T) {
 		l := checkEvaluationCount(t, 3) // New evaluation.
 		tcompare(t, l[2].Optional, false)
 	})
+
+	m, err = bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).FilterNotEqual("ID", sentMsg.ID).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get delivered message")
+	if !strings.Contains(string(m.MsgPrefix), "BIMI-Location: v=BIMI1; l=https://example.org/logo.svg\r\n") || !strings.Contains(string(m.MsgPrefix), "bimi=pass header.d=example.org") {
+		t.Fatalf("missing bimi headers in message prefix %q", m.MsgPrefix)
+	}
+}
+
+// Messages failing DMARC, forwarded by a trusted ARC sealer that recorded a DMARC
+// pass, are not rejected.
+func TestTrustedARCSealer(t *testing.T) {
+	privKey := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)) // Fake key, don't use this for real!
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"forward.example.": {"127.0.0.10"}, // For mx check.
+		},
+		TXT: map[string][]string{
+			"example.org.":                        {"v=spf1 ip4:127.0.0.1 -all"},
+			"_dmarc.example.org.":                 {"v=DMARC1;p=reject"},
+			"testsel._domainkey.forward.example.": {"v=DKIM1;k=ed25519;p=" + base64.StdEncoding.EncodeToString(privKey.Public().(ed25519.PublicKey))},
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"forward.example."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+	defer func() {
+		mox.Conf.Static.TrustedARCSealerDomains = nil
+	}()
+
+	sel := dkim.Selector{Hash: "sha256", PrivateKey: privKey, Headers: []string{"From", "To", "Subject"}, Domain: dns.Domain{ASCII: "testsel"}}
+	seal := func(authResults string) string {
+		t.Helper()
+		headers, err := dkim.ARCSeal(ctxbg, pkglog.Logger, dns.Domain{ASCII: "forward.example"}, sel, dkim.ARCNone, authResults, strings.NewReader(deliverMessage))
+		tcheck(t, err, "arc seal")
+		return headers + deliverMessage
+	}
+	msgPass := seal("Authentication-Results: forward.example; dmarc=pass header.from=example.org\r\n")
+	msgFail := seal("Authentication-Results: forward.example; dmarc=fail header.from=example.org\r\n")
+
+	deliver := func(msg string, expErr *smtpclient.Error) {
+		t.Helper()
+		ts.run(func(client *smtpclient.Client) {
+			mailFrom := "remote@forward.example"
+			rcptTo := "mjl@mox.example"
+			err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(msg)), strings.NewReader(msg), false, false, false)
+			ts.smtpErr(err, expErr)
+		})
+	}
+
+	dmarcErr := &smtpclient.Error{Permanent: true, Code: smtp.C550MailboxUnavail, Secode: smtp.SePol7MultiAuthFails26}
+
+	// Sealer not trusted, DMARC policy is applied.
+	deliver(msgPass, dmarcErr)
+
+	mox.Conf.Static.TrustedARCSealerDomains = []dns.Domain{{ASCII: "forward.example"}}
+
+	// Trusted sealer did not see a DMARC pass.
+	deliver(msgFail, dmarcErr)
+
+	// Trusted sealer saw a DMARC pass, policy is overridden.
+	deliver(msgPass, nil)
+
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get delivered message")
+	if !strings.Contains(string(m.MsgPrefix), "override trusted_forwarder") {
+		t.Fatalf("missing dmarc override in message prefix %q", m.MsgPrefix)
+	}
+	tcompare(t, m.MsgFromValidated, true)
+	tcompare(t, m.MsgFromValidation, store.ValidationDMARC)
+
+	// Authentication results are stored with the message.
+	tcompare(t, m.Auth != nil, true)
+	tcompare(t, m.Auth.IPRev, "pass")
+	tcompare(t, m.Auth.SPF, "none")
+	tcompare(t, m.Auth.SPFDomain, "forward.example")
+	tcompare(t, m.Auth.DMARC, "fail")
+	tcompare(t, m.Auth.DMARCDomain, "example.org")
+	tcompare(t, m.Auth.DMARCPolicy, "reject")
+	tcompare(t, m.Auth.DMARCOverrides, []string{"trusted_forwarder"})
+	tcompare(t, m.Auth.ARCSealer, "forward.example")
 }
 
 // Test DNSBL, then getting through with subjectpass.
@@ -1041,6 +1698,11 @@ func TestBlocklistedSubjectpass(t *testing.T) {
 	ts.dnsbls = []dns.Domain{{ASCII: "dnsbl.example"}}
 	defer ts.close()
 
+	// Keep rejected messages, for checking the stored DNSBL result.
+	acc := mox.Conf.Dynamic.Accounts[ts.acc.Name]
+	acc.RejectsMailbox = "Rejects"
+	mox.Conf.Dynamic.Accounts[ts.acc.Name] = acc
+
 	// Message should be refused softly (temporary error) due to DNSBL.
 	ts.run(func(client *smtpclient.Client) {
 		mailFrom := "remote@example.org"
@@ -1049,8 +1711,14 @@ func TestBlocklistedSubjectpass(t *testing.T) {
 		ts.smtpErr(err, &smtpclient.Error{Permanent: false, Code: smtp.C451LocalErr, Secode: smtp.SeSys3Other0})
 	})
 
+	// DNSBL result is stored with the message in the rejects mailbox.
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get rejected message")
+	tcompare(t, m.IsReject, true)
+	tcompare(t, m.Auth.DNSBLs, []store.MessageAuthDNSBL{{Zone: "dnsbl.example", Status: "fail"}})
+
 	// Set up subjectpass on account.
-	acc := mox.Conf.Dynamic.Accounts[ts.acc.Name]
+	acc = mox.Conf.Dynamic.Accounts[ts.acc.Name]
 	acc.SubjectPass.Period = time.Hour
 	mox.Conf.Dynamic.Accounts[ts.acc.Name] = acc
 
@@ -1077,6 +1745,57 @@ func TestBlocklistedSubjectpass(t *testing.T) {
 	})
 }
 
+// Test exemptions for trusted networks skip the DNSBL and the junk filter.
+func TestExemptions(t *testing.T) {
+	resolver := &dns.MockResolver{
+		A: map[string][]string{
+			"example.org.":              {"127.0.0.10"}, // For mx check.
+			"2.0.0.127.dnsbl.example.":  {"127.0.0.2"},  // For healthcheck.
+			"10.0.0.127.dnsbl.example.": {"127.0.0.10"}, // Where our connection pretends to come from.
+		},
+		TXT: map[string][]string{
+			"example.org.":        {"v=spf1 ip4:127.0.0.10 -all"},
+			"_dmarc.example.org.": {"v=DMARC1;p=reject"},
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	ts.dnsbls = []dns.Domain{{ASCII: "dnsbl.example"}}
+	defer ts.close()
+
+	_, ipnet, err := net.ParseCIDR("127.0.0.0/24")
+	tcheck(t, err, "parse cidr")
+	mox.Conf.Static.Exemptions = []config.Exemption{{Comment: "internal relay", IPNets: []net.IPNet{*ipnet}, DNSBL: true}}
+	defer func() {
+		mox.Conf.Static.Exemptions = nil
+	}()
+
+	// Not checked against DNSBL, accepted.
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "remote@example.org"
+		rcptTo := "mjl@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+		tcheck(t, err, "deliver")
+	})
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get delivered message")
+	tcompare(t, len(m.Auth.DNSBLs), 0)
+
+	// Junk filtering is skipped.
+	mox.Conf.Static.Exemptions[0].JunkFilter = true
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "remote@example.org"
+		rcptTo := "mjl@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+		tcheck(t, err, "deliver")
+	})
+	m, err = bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).SortDesc("ID").Limit(1).Get()
+	tcheck(t, err, "get delivered message")
+	tcompare(t, strings.Contains(string(m.MsgPrefix), "X-Mox-Reason: exempt"), true)
+}
+
 // Test accepting a DMARC report.
 func TestDMARCReport(t *testing.T) {
 	resolver := &dns.MockResolver{
@@ -1936,6 +2655,86 @@ func TestFutureRelease(t *testing.T) {
 	test(" HOLDFOR=1 HOLDUNTIL="+time.Now().Add(time.Hour).UTC().Format(time.RFC3339), "501")                        // Duplicate.
 }
 
+// Test MT-PRIORITY parameter on submission.
+func TestMTPriority(t *testing.T) {
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), dns.MockResolver{})
+	ts.tlsmode = smtpclient.TLSSkip
+	ts.user = "mjl@mox.example"
+	ts.pass = password0
+	ts.submission = true
+	defer ts.close()
+
+	ts.auth = func(mechanisms []string, cs *tls.ConnectionState) (sasl.Client, error) {
+		return sasl.NewClientPlain(ts.user, ts.pass), nil
+	}
+
+	test := func(mailtoMore, expResponsePrefix string, expPriority int) {
+		t.Helper()
+
+		ts.runRaw(func(conn net.Conn) {
+			t.Helper()
+
+			ourHostname := mox.Conf.Static.HostnameDomain
+			remoteHostname := dns.Domain{ASCII: "mox.example"}
+			opts := smtpclient.Opts{Auth: ts.auth}
+			log := pkglog.WithCid(ts.cid - 1)
+			client, err := smtpclient.New(ctxbg, log.Logger, conn, ts.tlsmode, false, ourHostname, remoteHostname, opts)
+			tcheck(t, err, "smtpclient")
+			defer conn.Close()
+			tcompare(t, client.SupportsMTPriority(), ts.submission)
+
+			write := func(s string) {
+				_, err := conn.Write([]byte(s))
+				tcheck(t, err, "write")
+			}
+
+			readPrefixLine := func(prefix string) {
+				t.Helper()
+				buf := make([]byte, 512)
+				n, err := conn.Read(buf)
+				tcheck(t, err, "read")
+				s := strings.TrimRight(string(buf[:n]), "\r\n")
+				if !strings.HasPrefix(s, prefix) {
+					t.Fatalf("got smtp response %q, expected line with prefix %q", s, prefix)
+				}
+			}
+
+			write(fmt.Sprintf("MAIL FROM:<mjl@mox.example>%s\r\n", mailtoMore))
+			readPrefixLine(expResponsePrefix)
+			if expResponsePrefix != "2" {
+				return
+			}
+			write("RCPT TO:<mjl@mox.example>\r\n")
+			readPrefixLine("2")
+
+			write("DATA\r\n")
+			readPrefixLine("3")
+			write("From: <mjl@mox.example>\r\n\r\nbody\r\n\r\n.\r\n")
+			readPrefixLine("2")
+
+			msgs, err := queue.List(ctxbg, queue.Filter{}, queue.Sort{Field: "Queued", Asc: false})
+			tcheck(t, err, "list queue")
+			tcompare(t, msgs[0].Priority, expPriority)
+		})
+	}
+
+	test("", "2", 0)
+	test(" MT-PRIORITY=-3", "2", -3)
+	test(" MT-PRIORITY=+9", "2", 9)
+	test(" MT-PRIORITY=4", "2", 4)
+	test(" MT-PRIORITY=0", "2", 0)
+
+	test(" MT-PRIORITY=+0", "501", 0)              // Sign not allowed for 0.
+	test(" MT-PRIORITY=10", "501", 0)              // Out of range.
+	test(" MT-PRIORITY=-10", "501", 0)             // Out of range.
+	test(" MT-PRIORITY=1 MT-PRIORITY=1", "501", 0) // Duplicate.
+
+	// Not accepted from unauthenticated remote servers.
+	ts.submission = false
+	ts.auth = nil
+	test(" MT-PRIORITY=1", "555", 0)
+}
+
 // Test SMTPUTF8
 func TestSMTPUTF8(t *testing.T) {
 	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), dns.MockResolver{})
@@ -2154,3 +2953,77 @@ func TestDestinationMessageAuthRequiredSMTPError(t *testing.T) {
 		ts.smtpErr(err, nil)
 	})
 }
+
+// Connections with a blocked TLS client fingerprint are rejected.
+func TestTLSFingerprint(t *testing.T) {
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), dns.MockResolver{})
+	defer ts.close()
+	ts.immediateTLS = true
+	ts.clientConfig = &tls.Config{InsecureSkipVerify: true}
+
+	// Fingerprint the TLS client config used for test connections.
+	clientConn, serverConn := net.Pipe()
+	go func() {
+		tls.Client(clientConn, ts.clientConfig).Handshake()
+		clientConn.Close()
+	}()
+	fpConn := &tlsfp.Conn{Conn: serverConn}
+	tls.Server(fpConn, ts.serverConfig).Handshake()
+	serverConn.Close()
+	hello, err := fpConn.ClientHello()
+	tcheck(t, err, "parse clienthello")
+
+	l := mox.Conf.Static.Listeners["test"]
+	l.SMTP.TLSFingerprintsBlocked = []string{hello.JA4()}
+	mox.Conf.Static.Listeners["test"] = l
+	defer delete(mox.Conf.Static.Listeners, "test")
+
+	ts.runRaw(func(conn net.Conn) {
+		defer conn.Close()
+		line, err := bufio.NewReader(conn).ReadString('\n')
+		tcheck(t, err, "read response")
+		if !strings.HasPrefix(line, "554 5.7.0 ") {
+			t.Fatalf("got response %q, expected 554 rejection", line)
+		}
+	})
+}
+
+// Test rules from the webmail are applied to incoming messages, and forwards are
+// queued.
+func TestRules(t *testing.T) {
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"example.org.": {"127.0.0.10"}, // For mx check.
+		},
+		PTR: map[string][]string{
+			"127.0.0.10": {"example.org."}, // For iprev check.
+		},
+	}
+	ts := newTestServer(t, filepath.FromSlash("../testdata/smtp/mox.conf"), resolver)
+	defer ts.close()
+
+	rule := store.Rule{Name: "test", From: "remote@example.org", Mailbox: "Archive", MarkRead: true, ForwardTo: []string{"other@example.org"}}
+	err := ts.acc.DB.Insert(ctxbg, &rule)
+	tcheck(t, err, "insert rule")
+
+	ts.run(func(client *smtpclient.Client) {
+		mailFrom := "remote@example.org"
+		rcptTo := "mjl@mox.example"
+		err := client.Deliver(ctxbg, mailFrom, rcptTo, int64(len(deliverMessage)), strings.NewReader(deliverMessage), false, false, false)
+		tcheck(t, err, "deliver")
+	})
+
+	m, err := bstore.QueryDB[store.Message](ctxbg, ts.acc.DB).FilterEqual("Expunged", false).Get()
+	tcheck(t, err, "get message")
+	tcompare(t, m.Seen, true)
+	mb, err := bstore.QueryDB[store.Mailbox](ctxbg, ts.acc.DB).FilterID(m.MailboxID).Get()
+	tcheck(t, err, "get mailbox")
+	tcompare(t, mb.Name, "Archive")
+
+	msgs, err := queue.List(ctxbg, queue.Filter{}, queue.Sort{})
+	tcheck(t, err, "queue list")
+	tcompare(t, len(msgs), 1)
+	tcompare(t, msgs[0].Sender().String(), "mjl@mox.example")
+	tcompare(t, msgs[0].Recipient().String(), "other@example.org")
+	tcompare(t, msgs[0].Subject, "Fwd: test")
+}
@@ -0,0 +1,82 @@
+package smtpserver
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/reputationdb"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webops"
+)
+
+// Number of messages to spamtraps from a single IP that are trained in the shared
+// junk filter. Limits the influence of a single sender on the junk filter.
+const spamtrapTrainMax = 10
+
+// spamtrapHit handles a message sent to a spamtrap address, which is never
+// delivered. The server-wide reputation of the sending IP and, if validated, the
+// organizational domain of the message From address are updated, and the message
+// is trained as spam in the shared junk filter of the domain of the spamtrap, if
+// any. Recently received messages from the sender are moved to the Junk mailbox
+// in accounts with junk re-evaluation for spamtraps enabled.
+//
+// Against poisoning of the trap, e.g. by someone forging messages of a legitimate
+// sender, messages are not trained for senders with an allow override or for
+// which users have marked messages as not junk, and only the first messages of an
+// IP are trained. Bounces, with a null reverse path, are ignored completely: they
+// are likely backscatter for messages with the spamtrap as forged sender.
+func spamtrapHit(ctx context.Context, log mlog.Log, rcptTo, mailFrom smtp.Path, isDSN bool, senderDomain, ip string, dataFile *os.File, size int64) {
+	log = log.With(slog.Any("spamtrap", rcptTo))
+	if mailFrom.IsZero() || isDSN {
+		log.Info("ignoring bounce to spamtrap")
+		metricDelivery.WithLabelValues("spamtrap", "bounce").Inc()
+		return
+	}
+
+	senders, err := reputationdb.SpamtrapHit(ctx, senderDomain, ip)
+	if err != nil {
+		log.Errorx("updating sender reputation for spamtrap", err)
+		metricDelivery.WithLabelValues("spamtrap", "error").Inc()
+		return
+	}
+
+	train := true
+	for _, s := range senders {
+		if s.Override == reputationdb.OverrideAllow || s.Notjunk > 0 || s.Type == reputationdb.SenderIP && s.Spamtraps > spamtrapTrainMax {
+			log.Debug("not training message to spamtrap",
+				slog.Any("sendertype", s.Type),
+				slog.String("sender", s.Value),
+				slog.Any("override", s.Override),
+				slog.Int64("notjunk", s.Notjunk),
+				slog.Int64("spamtraps", s.Spamtraps))
+			train = false
+			break
+		}
+	}
+	if train {
+		webops.JunkReevaluateSpamtrapHit(log, senderDomain, ip)
+
+		err := store.WithSharedJunkFilter(ctx, log, rcptTo.IPDomain.Domain, func(jf *junk.Filter, _ config.SharedJunkFilter) error {
+			return jf.TrainMessage(ctx, dataFile, size, false)
+		})
+		if errors.Is(err, store.ErrNoJunkFilter) {
+			train = false
+		} else if err != nil {
+			log.Errorx("training shared junk filter with message to spamtrap", err)
+			train = false
+		}
+	}
+
+	log.Info("message to spamtrap", slog.String("senderdomain", senderDomain), slog.String("ip", ip), slog.Bool("trained", train))
+	if train {
+		metricDelivery.WithLabelValues("spamtrap", "trained").Inc()
+	} else {
+		metricDelivery.WithLabelValues("spamtrap", "").Inc()
+	}
+}
@@ -0,0 +1,115 @@
+package spamfilter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxvar"
+)
+
+// Rspamd checks messages with the HTTP API of rspamd, at /checkv2.
+type Rspamd struct {
+	URL      string // Base URL, e.g. http://localhost:11333.
+	Password string // Optional, sent in the Password header.
+}
+
+func (Rspamd) Name() string {
+	return "rspamd"
+}
+
+// rspamdResponse is the relevant part of a response of /checkv2.
+type rspamdResponse struct {
+	IsSkipped     bool    `json:"is_skipped"`
+	Score         float64 `json:"score"`
+	RequiredScore float64 `json:"required_score"`
+	Action        string  `json:"action"`
+	Symbols       map[string]struct {
+		Name  string  `json:"name"`
+		Score float64 `json:"score"`
+	} `json:"symbols"`
+}
+
+// Check submits the message to rspamd.
+func (r Rspamd) Check(ctx context.Context, log mlog.Log, msg io.Reader, size int64, meta Meta) (Result, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", strings.TrimSuffix(r.URL, "/")+"/checkv2", msg)
+	if err != nil {
+		return Result{}, fmt.Errorf("making request: %v", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("User-Agent", "mox/"+moxvar.Version)
+	if r.Password != "" {
+		req.Header.Set("Password", r.Password)
+	}
+	if meta.RemoteIP != nil {
+		req.Header.Set("IP", meta.RemoteIP.String())
+	}
+	if meta.Hostname != "" {
+		req.Header.Set("MTA-Name", meta.Hostname)
+	}
+	if meta.EHLO != "" {
+		req.Header.Set("Helo", meta.EHLO)
+	}
+	if meta.MailFrom != "" {
+		req.Header.Set("From", meta.MailFrom)
+	}
+	if meta.RcptTo != "" {
+		req.Header.Set("Rcpt", meta.RcptTo)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("rspamd responded with status %s", resp.Status)
+	}
+	var rr rspamdResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1024*1024)).Decode(&rr); err != nil {
+		return Result{}, fmt.Errorf("parsing rspamd response: %v", err)
+	}
+	if rr.IsSkipped {
+		return Result{Action: rr.Action}, nil
+	}
+
+	result := Result{
+		Score:     rr.Score,
+		Threshold: rr.RequiredScore,
+		Action:    rr.Action,
+	}
+	// Actions other than these indicate spam. "soft reject" is for rate limiting and
+	// greylisting, not about content.
+	switch rr.Action {
+	case "no action", "greylist", "soft reject":
+	default:
+		result.Spam = true
+	}
+	for name, sym := range rr.Symbols {
+		if sym.Name != "" {
+			name = sym.Name
+		}
+		if validSymbol(name) {
+			result.Symbols = append(result.Symbols, name)
+		}
+	}
+	slices.Sort(result.Symbols)
+	return result, nil
+}
+
+// validSymbol returns whether s is safe to include in a message header.
+func validSymbol(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c <= ' ' || c >= 0x7f || c == ',' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package spamfilter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/mjl-/mox/mlog"
+)
+
+// Spamd checks messages with SpamAssassin's spamd, using the protocol of spamc.
+type Spamd struct {
+	Address string // Host and port, e.g. localhost:783.
+}
+
+func (Spamd) Name() string {
+	return "spamd"
+}
+
+// Check submits the message to spamd with the SYMBOLS command.
+func (s Spamd) Check(ctx context.Context, log mlog.Log, msg io.Reader, size int64, meta Meta) (Result, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.Address)
+	if err != nil {
+		return Result{}, fmt.Errorf("connecting to spamd: %v", err)
+	}
+	defer func() {
+		err := conn.Close()
+		log.Check(err, "closing connection to spamd")
+	}()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	// The message is sent as is. Spamd uses the Received header we add for the remote
+	// IP and EHLO hostname.
+	bw := bufio.NewWriter(conn)
+	fmt.Fprintf(bw, "SYMBOLS SPAMC/1.5\r\nContent-length: %d\r\n\r\n", size)
+	if _, err := io.Copy(bw, msg); err != nil {
+		return Result{}, fmt.Errorf("writing message to spamd: %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return Result{}, fmt.Errorf("writing message to spamd: %v", err)
+	}
+	if tc, ok := conn.(*net.TCPConn); ok {
+		tc.CloseWrite()
+	}
+
+	return parseSpamdResponse(bufio.NewReader(io.LimitReader(conn, 1024*1024)))
+}
+
+// parseSpamdResponse parses a response to a SYMBOLS command, e.g.:
+//
+//	SPAMD/1.1 0 EX_OK
+//	Content-length: 24
+//	Spam: True ; 15.3 / 5.0
+//
+//	BAYES_99,URIBL_BLACK
+func parseSpamdResponse(r *bufio.Reader) (Result, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return Result{}, fmt.Errorf("reading spamd response: %v", err)
+	}
+	t := strings.Fields(line)
+	if len(t) < 3 || !strings.HasPrefix(t[0], "SPAMD/") {
+		return Result{}, fmt.Errorf("malformed spamd response %q", strings.TrimSpace(line))
+	} else if t[1] != "0" {
+		return Result{}, fmt.Errorf("spamd error: %s", strings.Join(t[1:], " "))
+	}
+
+	var result Result
+	var haveSpam bool
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return Result{}, fmt.Errorf("reading spamd response header: %v", err)
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		k, v, ok := strings.Cut(line, ":")
+		if !ok || !strings.EqualFold(k, "Spam") {
+			continue
+		}
+		// E.g. "True ; 15.3 / 5.0".
+		flag, scores, ok := strings.Cut(v, ";")
+		score, threshold, ok2 := strings.Cut(scores, "/")
+		if !ok || !ok2 {
+			return Result{}, fmt.Errorf("malformed spam header %q in spamd response", line)
+		}
+		result.Spam = strings.EqualFold(strings.TrimSpace(flag), "true") || strings.EqualFold(strings.TrimSpace(flag), "yes")
+		if result.Score, err = strconv.ParseFloat(strings.TrimSpace(score), 64); err != nil {
+			return Result{}, fmt.Errorf("parsing score in spamd response: %v", err)
+		}
+		if result.Threshold, err = strconv.ParseFloat(strings.TrimSpace(threshold), 64); err != nil {
+			return Result{}, fmt.Errorf("parsing threshold in spamd response: %v", err)
+		}
+		haveSpam = true
+	}
+	if !haveSpam {
+		return Result{}, fmt.Errorf("missing spam header in spamd response")
+	}
+
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, fmt.Errorf("reading symbols from spamd response: %v", err)
+	}
+	for _, sym := range strings.Split(strings.TrimSpace(string(body)), ",") {
+		sym = strings.TrimSpace(sym)
+		if validSymbol(sym) {
+			result.Symbols = append(result.Symbols, sym)
+		}
+	}
+	return result, nil
+}
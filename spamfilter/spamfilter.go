@@ -0,0 +1,77 @@
+// Package spamfilter checks incoming messages with an external spam filter, rspamd
+// through its HTTP API, or SpamAssassin through the spamd protocol.
+package spamfilter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/mjl-/mox/mlog"
+)
+
+// Meta is information about an SMTP transaction, passed to the filter along with
+// the message.
+type Meta struct {
+	RemoteIP net.IP
+	Hostname string // Our hostname.
+	EHLO     string // Hostname from EHLO/HELO command.
+	MailFrom string // SMTP MAIL FROM address, can be empty.
+	RcptTo   string // SMTP RCPT TO address.
+}
+
+// Result is the verdict of an external spam filter.
+type Result struct {
+	Spam      bool     // Whether the filter considers the message spam.
+	Score     float64  // Higher is more spammy.
+	Threshold float64  // Score at which the filter considers a message spam, can be 0 if unknown.
+	Action    string   // Action recommended by the filter, e.g. "no action" or "reject" for rspamd, empty for spamd.
+	Symbols   []string // Names of rules that matched.
+}
+
+// Filter is an external spam filter.
+type Filter interface {
+	// Name of the filter, e.g. "rspamd".
+	Name() string
+
+	// Check returns the verdict of the filter for a message of size bytes.
+	Check(ctx context.Context, log mlog.Log, msg io.Reader, size int64, meta Meta) (Result, error)
+}
+
+// Headers returns X-Spam-* header lines for a result, each ending with crlf.
+func (r Result) Headers(filter string) string {
+	status := "No"
+	if r.Spam {
+		status = "Yes"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "X-Spam-Status: %s, score=%.2f required=%.2f filter=%s\r\n", status, r.Score, r.Threshold, filter)
+	fmt.Fprintf(&b, "X-Spam-Score: %.2f\r\n", r.Score)
+	if r.Spam {
+		b.WriteString("X-Spam-Flag: YES\r\n")
+	}
+	if len(r.Symbols) > 0 {
+		// Symbols can be long, we fold the header.
+		b.WriteString("X-Spam-Symbols:")
+		n := len("X-Spam-Symbols:")
+		for i, s := range r.Symbols {
+			if i > 0 {
+				b.WriteString(",")
+				n++
+			}
+			if n+1+len(s) > 78 {
+				b.WriteString("\r\n\t")
+				n = 1
+			} else {
+				b.WriteString(" ")
+				n++
+			}
+			b.WriteString(s)
+			n += len(s)
+		}
+		b.WriteString("\r\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,138 @@
+package spamfilter
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/mlog"
+)
+
+var ctxbg = context.Background()
+
+var pkglog = mlog.New("spamfilter", nil)
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+const msg = "From: <remote@example.org>\r\nSubject: test\r\n\r\ntest\r\n"
+
+func TestRspamd(t *testing.T) {
+	var response string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/checkv2" || r.Header.Get("Password") != "secret" || r.Header.Get("IP") != "127.0.0.10" || r.Header.Get("Rcpt") != "mjl@mox.example" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		buf, _ := io.ReadAll(r.Body)
+		if string(buf) != msg {
+			http.Error(w, "bad message", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, response)
+	}))
+	defer srv.Close()
+
+	filter := Rspamd{URL: srv.URL + "/", Password: "secret"}
+	meta := Meta{RemoteIP: net.ParseIP("127.0.0.10"), RcptTo: "mjl@mox.example"}
+	check := func() Result {
+		t.Helper()
+		r, err := filter.Check(ctxbg, pkglog, strings.NewReader(msg), int64(len(msg)), meta)
+		tcheck(t, err, "check")
+		return r
+	}
+
+	response = `{"is_skipped": false, "score": 12.5, "required_score": 15, "action": "add header", "symbols": {"BAYES_SPAM": {"name": "BAYES_SPAM", "score": 5.1}, "R_SPF_FAIL": {"name": "R_SPF_FAIL", "score": 1}, "BAD\r\nHEADER": {"score": 1}}}`
+	r := check()
+	tcompare(t, r.Spam, true)
+	tcompare(t, r.Score, 12.5)
+	tcompare(t, r.Threshold, 15.0)
+	tcompare(t, r.Symbols, []string{"BAYES_SPAM", "R_SPF_FAIL"})
+
+	response = `{"is_skipped": false, "score": 1, "required_score": 15, "action": "no action", "symbols": {}}`
+	r = check()
+	tcompare(t, r.Spam, false)
+
+	// Wrong password results in an error.
+	filter.Password = "other"
+	_, err := filter.Check(ctxbg, pkglog, strings.NewReader(msg), int64(len(msg)), meta)
+	if err == nil {
+		t.Fatalf("expected error for bad response")
+	}
+}
+
+func TestSpamd(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	tcheck(t, err, "listen")
+	defer ln.Close()
+
+	var response string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			br := bufio.NewReader(conn)
+			line, _ := br.ReadString('\n')
+			if line != "SYMBOLS SPAMC/1.5\r\n" {
+				conn.Close()
+				continue
+			}
+			io.ReadAll(br)
+			fmt.Fprint(conn, response)
+			conn.Close()
+		}
+	}()
+
+	filter := Spamd{Address: ln.Addr().String()}
+	response = "SPAMD/1.1 0 EX_OK\r\nContent-length: 24\r\nSpam: True ; 15.3 / 5.0\r\n\r\nBAYES_99,URIBL_BLACK"
+	r, err := filter.Check(ctxbg, pkglog, strings.NewReader(msg), int64(len(msg)), Meta{})
+	tcheck(t, err, "check")
+	tcompare(t, r.Spam, true)
+	tcompare(t, r.Score, 15.3)
+	tcompare(t, r.Threshold, 5.0)
+	tcompare(t, r.Symbols, []string{"BAYES_99", "URIBL_BLACK"})
+
+	response = "SPAMD/1.1 0 EX_OK\r\nSpam: False ; 0.5 / 5.0\r\n\r\n"
+	r, err = filter.Check(ctxbg, pkglog, strings.NewReader(msg), int64(len(msg)), Meta{})
+	tcheck(t, err, "check")
+	tcompare(t, r.Spam, false)
+	tcompare(t, len(r.Symbols), 0)
+
+	response = "SPAMD/1.0 76 Bad header line\r\n"
+	_, err = filter.Check(ctxbg, pkglog, strings.NewReader(msg), int64(len(msg)), Meta{})
+	if err == nil {
+		t.Fatalf("expected error for spamd error response")
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	r := Result{Spam: true, Score: 12.5, Threshold: 15, Symbols: []string{"BAYES_SPAM", "R_SPF_FAIL"}}
+	tcompare(t, r.Headers("rspamd"), "X-Spam-Status: Yes, score=12.50 required=15.00 filter=rspamd\r\nX-Spam-Score: 12.50\r\nX-Spam-Flag: YES\r\nX-Spam-Symbols: BAYES_SPAM, R_SPF_FAIL\r\n")
+
+	// Long lists of symbols are folded.
+	r = Result{Symbols: strings.Split(strings.Repeat("SYMBOL_NAME_ABC,", 10)+"LAST", ",")}
+	for _, line := range strings.Split(strings.TrimSuffix(r.Headers("spamd"), "\r\n"), "\r\n") {
+		if len(line) > 78 {
+			t.Fatalf("header line too long: %q", line)
+		}
+	}
+}
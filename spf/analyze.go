@@ -0,0 +1,405 @@
+package spf
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+)
+
+// Limits for analysis, so a broken or malicious record cannot make us do an
+// unbounded amount of lookups. Evaluation stops far earlier, at the RFC limits.
+const (
+	analyzeDepthMax   = 10
+	analyzeLookupsMax = 100
+)
+
+// Analysis is the result of fully expanding the SPF record of a domain, following
+// includes and redirects, for diagnostics. Unlike evaluation, analysis does not
+// stop at the limit of DNS lookups, so the total number of lookups can be
+// reported.
+type Analysis struct {
+	Domain dns.Domain
+	Record *AnalysisRecord // Record for Domain, with nested records for includes and redirect.
+
+	// Number of DNS lookups for mechanisms and modifiers that count against the
+	// limit of 10, regardless of the IP that is evaluated. A "p" macro adds a lookup
+	// during evaluation too.
+	DNSLookups int
+
+	// Number of lookups that resulted in no records, evaluation fails with more than
+	// 2.
+	VoidLookups int
+
+	// Whether all DNS responses were DNSSEC-verified.
+	Authentic bool
+
+	// Problems that cause evaluation to result in a permerror or temperror, e.g.
+	// exceeding the lookup limits, loops or missing included records.
+	Errors []string
+
+	// Potential problems, e.g. IP networks authorized multiple times, mechanisms
+	// that are never evaluated, or deprecated mechanisms.
+	Warnings []string
+}
+
+// AnalysisRecord is the SPF record for a domain, as found during analysis.
+type AnalysisRecord struct {
+	Domain     string // Domain for the record, after macro expansion.
+	TXT        string // As found in DNS, can be empty if not found.
+	Error      string // Error looking up or parsing the record.
+	Mechanisms []AnalysisMechanism
+}
+
+// AnalysisMechanism describes a directive or redirect modifier in a record.
+type AnalysisMechanism struct {
+	Mechanism  string // E.g. "-all", "include:example.com", or "redirect=example.com".
+	Expanded   string // Domain after macro expansion, if the mechanism had macros.
+	DNSLookups int    // Lookups counting against the limit, 0 or 1.
+	Void       bool   // Whether a lookup resulted in no records.
+	IPs        []string
+	Error      string
+	Include    *AnalysisRecord // For "include" and "redirect".
+}
+
+type analyzedNet struct {
+	net       net.IPNet
+	mechanism string
+	domain    string
+}
+
+type analyzer struct {
+	ctx      context.Context
+	log      mlog.Log
+	resolver dns.Resolver
+	analysis *Analysis
+	lookups  int             // All lookups, for limiting the work we do.
+	path     map[string]bool // Domains being analyzed, for detecting loops.
+	seen     map[string]bool // Domains analyzed, for detecting duplicates.
+	nets     []analyzedNet
+}
+
+// Analyze looks up the SPF record of the domain in args, and expands it fully,
+// following includes and redirects, and looking up the IPs of "a" and "mx"
+// mechanisms. Macros are expanded with args, which should be set as for Verify,
+// though RemoteIP can be nil.
+//
+// Errors in DNS lookups or records are reported in the returned analysis, an
+// error is only returned if args does not have a domain.
+func Analyze(ctx context.Context, elog *slog.Logger, resolver dns.Resolver, args Args) (analysis Analysis, rerr error) {
+	log := mlog.New("spf", elog)
+	start := time.Now()
+	defer func() {
+		log.Debugx("spf analyze result", rerr,
+			slog.Any("domain", analysis.Domain),
+			slog.Int("dnslookups", analysis.DNSLookups),
+			slog.Int("voidlookups", analysis.VoidLookups),
+			slog.Duration("duration", time.Since(start)))
+	}()
+
+	if _, ok := prepare(&args); !ok {
+		return Analysis{}, fmt.Errorf("no domain name to analyze")
+	}
+	if args.RemoteIP == nil {
+		// For macro expansion.
+		args.RemoteIP = net.IPv4zero
+	}
+	// Only used by the "p" macro, we don't enforce limits during analysis.
+	args.dnsRequests = new(int)
+	args.voidLookups = new(int)
+
+	analysis = Analysis{Domain: args.domain, Authentic: true}
+	z := &analyzer{
+		ctx:      ctx,
+		log:      log,
+		resolver: resolver,
+		analysis: &analysis,
+		path:     map[string]bool{},
+		seen:     map[string]bool{},
+	}
+	analysis.Record = z.record(args, 0)
+
+	// ../rfc/7208:937
+	if analysis.DNSLookups > dnsRequestsMax {
+		analysis.Errors = append(analysis.Errors, fmt.Sprintf("Record needs %d DNS lookups, more than the maximum of %d, evaluation results in a permerror once the limit is reached.", analysis.DNSLookups, dnsRequestsMax))
+	}
+	// ../rfc/7208:988
+	if analysis.VoidLookups > voidLookupsMax {
+		analysis.Errors = append(analysis.Errors, fmt.Sprintf("Record has %d lookups resulting in no records, more than the maximum of %d, evaluation results in a permerror once the limit is reached.", analysis.VoidLookups, voidLookupsMax))
+	}
+
+	// Check for IP networks that are authorized multiple times.
+	for i, a := range z.nets {
+		for _, b := range z.nets[i+1:] {
+			aones, _ := a.net.Mask.Size()
+			bones, _ := b.net.Mask.Size()
+			if aones <= bones && a.net.Contains(b.net.IP) || bones <= aones && b.net.Contains(a.net.IP) {
+				analysis.Warnings = append(analysis.Warnings, fmt.Sprintf("IP network %s from %q in record for %s overlaps with %s from %q in record for %s.", a.net.String(), a.mechanism, a.domain, b.net.String(), b.mechanism, b.domain))
+			}
+		}
+	}
+	return analysis, nil
+}
+
+func (z *analyzer) warnf(format string, args ...any) {
+	z.analysis.Warnings = append(z.analysis.Warnings, fmt.Sprintf(format, args...))
+}
+
+func (z *analyzer) errorf(format string, args ...any) {
+	z.analysis.Errors = append(z.analysis.Errors, fmt.Sprintf(format, args...))
+}
+
+// lookup registers a DNS lookup, returning false if we reached our limit.
+func (z *analyzer) lookup() bool {
+	z.lookups++
+	return z.lookups <= analyzeLookupsMax
+}
+
+func (z *analyzer) void(err error) bool {
+	if dns.IsNotFound(err) {
+		z.analysis.VoidLookups++
+		return true
+	}
+	return false
+}
+
+// record looks up the record for args.domain and analyzes its mechanisms.
+func (z *analyzer) record(args Args, depth int) *AnalysisRecord {
+	domain := args.domain.ASCII
+	ar := &AnalysisRecord{Domain: domain}
+	if depth > analyzeDepthMax {
+		ar.Error = "records nested too deeply"
+		z.errorf("Records nested too deeply at %s.", domain)
+		return ar
+	}
+	if z.path[domain] {
+		ar.Error = "loop"
+		z.errorf("Record for %s includes itself, directly or indirectly.", domain)
+		return ar
+	}
+	if z.seen[domain] {
+		z.warnf("Record for %s is included multiple times.", domain)
+	}
+	if !z.lookup() {
+		ar.Error = "too many lookups during analysis"
+		return ar
+	}
+	z.seen[domain] = true
+	z.path[domain] = true
+	defer delete(z.path, domain)
+
+	_, txt, record, authentic, err := Lookup(z.ctx, z.log.Logger, z.resolver, args.domain)
+	z.analysis.Authentic = z.analysis.Authentic && authentic
+	ar.TXT = txt
+	if err != nil {
+		ar.Error = err.Error()
+		if errors.Is(err, ErrNoRecord) {
+			z.analysis.VoidLookups++
+		}
+		if depth == 0 {
+			z.errorf("Looking up record for %s: %s", domain, err)
+		} else {
+			z.errorf("Looking up included record for %s: %s", domain, err)
+		}
+		return ar
+	}
+
+	var all bool
+	for _, d := range record.Directives {
+		if all {
+			z.warnf("Mechanism %q after \"all\" in record for %s is never evaluated.", d.MechanismString(), domain)
+		}
+		ar.Mechanisms = append(ar.Mechanisms, z.directive(args, d, depth))
+		if d.Mechanism == "all" {
+			all = true
+		}
+	}
+
+	if record.Redirect != "" {
+		am := AnalysisMechanism{Mechanism: "redirect=" + record.Redirect}
+		if all {
+			// ../rfc/7208:1423
+			z.warnf("Redirect in record for %s is ignored because the record has an \"all\" mechanism.", domain)
+		} else {
+			am.DNSLookups = 1
+			z.analysis.DNSLookups++
+			nargs, name, err := z.expand(args, record.Redirect)
+			am.Expanded = name
+			if err != nil {
+				am.Error = err.Error()
+				z.errorf("Expanding redirect in record for %s: %s", domain, err)
+			} else {
+				am.Include = z.record(nargs, depth+1)
+			}
+		}
+		ar.Mechanisms = append(ar.Mechanisms, am)
+	}
+	return ar
+}
+
+// expand expands macros in domainSpec, returning args for the resulting domain,
+// and the expanded name if domainSpec had macros.
+func (z *analyzer) expand(args Args, domainSpec string) (Args, string, error) {
+	name, authentic, err := expandDomainSpecDNS(z.ctx, z.resolver, domainSpec, args)
+	z.analysis.Authentic = z.analysis.Authentic && authentic
+	if err != nil {
+		return args, "", err
+	}
+	name = strings.TrimSuffix(name, ".")
+	var expanded string
+	if strings.Contains(domainSpec, "%") {
+		expanded = name
+	}
+	args.domain = dns.Domain{ASCII: name}
+	return args, expanded, nil
+}
+
+// hostIPs looks up the IPs for host, as for "a" and "mx" mechanisms.
+func (z *analyzer) hostIPs(am *AnalysisMechanism, host dns.Domain, d Directive, domain string) {
+	if !z.lookup() {
+		am.Error = "too many lookups during analysis"
+		return
+	}
+	ips, result, err := z.resolver.LookupIP(z.ctx, "ip", host.ASCII+".")
+	z.analysis.Authentic = z.analysis.Authentic && result.Authentic
+	if z.void(err) {
+		am.Void = true
+	} else if err != nil {
+		am.Error = err.Error()
+		z.errorf("Looking up IPs for %s in record for %s: %s", host, domain, err)
+	}
+	for _, ip := range ips {
+		ones, bits := 32, 32
+		if d.IP4CIDRLen != nil {
+			ones = *d.IP4CIDRLen
+		}
+		if ip.To4() == nil {
+			ones, bits = 128, 128
+			if d.IP6CIDRLen != nil {
+				ones = *d.IP6CIDRLen
+			}
+		}
+		z.addNet(am, ip, ones, bits, d, domain)
+	}
+}
+
+func (z *analyzer) addNet(am *AnalysisMechanism, ip net.IP, ones, bits int, d Directive, domain string) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	ipnet := net.IPNet{IP: ip.Mask(net.CIDRMask(ones, bits)), Mask: net.CIDRMask(ones, bits)}
+	am.IPs = append(am.IPs, ipnet.String())
+	// Only authorizing networks are relevant for overlap.
+	if d.Qualifier == "" || d.Qualifier == "+" {
+		z.nets = append(z.nets, analyzedNet{ipnet, d.MechanismString(), domain})
+	}
+}
+
+func (z *analyzer) directive(args Args, d Directive, depth int) AnalysisMechanism {
+	domain := args.domain.ASCII
+	am := AnalysisMechanism{Mechanism: d.MechanismString()}
+
+	switch d.Mechanism {
+	case "include", "a", "mx", "ptr", "exists":
+		am.DNSLookups = 1
+		z.analysis.DNSLookups++
+	}
+
+	switch d.Mechanism {
+	case "all":
+		if d.Qualifier == "" || d.Qualifier == "+" {
+			z.warnf("Mechanism %q in record for %s allows all IPs to send.", d.MechanismString(), domain)
+		}
+
+	case "include":
+		nargs, name, err := z.expand(args, d.DomainSpec)
+		am.Expanded = name
+		if err != nil {
+			am.Error = err.Error()
+			z.errorf("Expanding include in record for %s: %s", domain, err)
+			break
+		}
+		am.Include = z.record(nargs, depth+1)
+
+	case "a":
+		host, err := evaluateDomainSpec(d.DomainSpec, args.domain)
+		if err != nil {
+			am.Error = err.Error()
+			z.errorf("Mechanism %q in record for %s: %s", d.MechanismString(), domain, err)
+			break
+		}
+		z.hostIPs(&am, host, d, domain)
+
+	case "mx":
+		host, err := evaluateDomainSpec(d.DomainSpec, args.domain)
+		if err != nil {
+			am.Error = err.Error()
+			z.errorf("Mechanism %q in record for %s: %s", d.MechanismString(), domain, err)
+			break
+		}
+		if !z.lookup() {
+			am.Error = "too many lookups during analysis"
+			break
+		}
+		mxs, result, err := z.resolver.LookupMX(z.ctx, host.ASCII+".")
+		z.analysis.Authentic = z.analysis.Authentic && result.Authentic
+		if z.void(err) {
+			am.Void = true
+			break
+		} else if err != nil {
+			am.Error = err.Error()
+			z.errorf("Looking up MX records for %s in record for %s: %s", host, domain, err)
+			break
+		}
+		if len(mxs) == 1 && mxs[0].Host == "." {
+			break
+		}
+		// ../rfc/7208:945
+		if len(mxs) > 10 {
+			z.errorf("Mechanism %q in record for %s has %d MX hosts, more than the maximum of 10.", d.MechanismString(), domain, len(mxs))
+		}
+		for _, mx := range mxs {
+			mxd, err := dns.ParseDomainLax(strings.TrimSuffix(mx.Host, "."))
+			if err != nil {
+				am.Error = err.Error()
+				z.errorf("Parsing MX host %q for %s in record for %s: %s", mx.Host, host, domain, err)
+				continue
+			}
+			z.hostIPs(&am, mxd, d, domain)
+		}
+
+	case "ptr":
+		// ../rfc/7208:1284
+		z.warnf("Mechanism %q in record for %s is deprecated, it is slow and unreliable and some receivers ignore it.", d.MechanismString(), domain)
+
+	case "ip4", "ip6":
+		ones, bits := 32, 32
+		if d.IP4CIDRLen != nil {
+			ones = *d.IP4CIDRLen
+		}
+		if d.Mechanism == "ip6" {
+			ones, bits = 128, 128
+			if d.IP6CIDRLen != nil {
+				ones = *d.IP6CIDRLen
+			}
+		}
+		z.addNet(&am, d.IP, ones, bits, d, domain)
+
+	case "exists":
+		_, name, err := z.expand(args, d.DomainSpec)
+		am.Expanded = name
+		if err != nil {
+			am.Error = err.Error()
+			z.errorf("Expanding exists in record for %s: %s", domain, err)
+		}
+		// The lookup depends on the macros expanded for the evaluated message, we don't
+		// resolve it.
+	}
+	return am
+}
@@ -0,0 +1,119 @@
+package spf
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/dns"
+)
+
+func TestAnalyze(t *testing.T) {
+	resolver := dns.MockResolver{
+		TXT: map[string][]string{
+			"example.com.":               {"v=spf1 ip4:192.0.2.0/24 a mx include:_spf.example.net include:%{d}.macro.example redirect=other.example"},
+			"_spf.example.net.":          {"v=spf1 ip4:192.0.2.10 ip6:2001:db8::/32 ptr ~all"},
+			"example.com.macro.example.": {"v=spf1 exists:%{i}.exists.example -all"},
+			"loop.example.":              {"v=spf1 include:loop2.example -all"},
+			"loop2.example.":             {"v=spf1 include:loop.example -all"},
+			"void.example.":              {"v=spf1 a:a1.void.example a:a2.void.example include:missing.example -all"},
+			"many.example.":              {"v=spf1 " + strings.Repeat("a ", 11) + "-all"},
+			"afterall.example.":          {"v=spf1 -all ip4:192.0.2.1"},
+			"multiple.example.":          {"v=spf1 -all", "v=spf1 -all"},
+		},
+		A: map[string][]string{
+			"example.com.":    {"192.0.2.1"},
+			"mx.example.com.": {"198.51.100.1"},
+			"many.example.":   {"198.51.100.1"},
+		},
+		MX: map[string][]*net.MX{
+			"example.com.": {{Host: "mx.example.com.", Pref: 10}},
+		},
+	}
+
+	analyze := func(domain string) Analysis {
+		t.Helper()
+		args := Args{
+			MailFromLocalpart: "user",
+			MailFromDomain:    dns.Domain{ASCII: domain},
+			RemoteIP:          net.ParseIP("198.51.100.10"),
+		}
+		a, err := Analyze(context.Background(), pkglog.Logger, resolver, args)
+		if err != nil {
+			t.Fatalf("analyze %s: %v", domain, err)
+		}
+		return a
+	}
+	hasString := func(l []string, s string) bool {
+		for _, e := range l {
+			if strings.Contains(e, s) {
+				return true
+			}
+		}
+		return false
+	}
+
+	a := analyze("example.com")
+	// a, mx, 2 includes, redirect, ptr and exists in includes.
+	if a.DNSLookups != 7 || a.VoidLookups != 1 {
+		t.Fatalf("got dnslookups %d, voidlookups %d, expected 7, 1", a.DNSLookups, a.VoidLookups)
+	}
+	if len(a.Record.Mechanisms) != 6 {
+		t.Fatalf("got %d mechanisms, expected 6", len(a.Record.Mechanisms))
+	}
+	if ips := a.Record.Mechanisms[1].IPs; !reflect.DeepEqual(ips, []string{"192.0.2.1/32"}) {
+		t.Fatalf("got ips %v for a mechanism", ips)
+	}
+	if ips := a.Record.Mechanisms[2].IPs; !reflect.DeepEqual(ips, []string{"198.51.100.1/32"}) {
+		t.Fatalf("got ips %v for mx mechanism", ips)
+	}
+	include := a.Record.Mechanisms[3].Include
+	if include == nil || include.Domain != "_spf.example.net" || !reflect.DeepEqual(include.Mechanisms[1].IPs, []string{"2001:db8::/32"}) {
+		t.Fatalf("unexpected include %#v", include)
+	}
+	if m := a.Record.Mechanisms[4]; m.Expanded != "example.com.macro.example" || m.Include == nil || m.Include.Mechanisms[0].Expanded != "198.51.100.10.exists.example" {
+		t.Fatalf("unexpected macro expansion %#v", m)
+	}
+	if m := a.Record.Mechanisms[5]; m.Mechanism != "redirect=other.example" || m.Include == nil || m.Include.Error == "" {
+		t.Fatalf("unexpected redirect %#v", m)
+	}
+	// 192.0.2.1 from "a" and 192.0.2.10 from include are in 192.0.2.0/24.
+	if len(a.Warnings) != 3 || !hasString(a.Warnings, "192.0.2.0/24") || !hasString(a.Warnings, "deprecated") {
+		t.Fatalf("unexpected warnings %v", a.Warnings)
+	}
+	if len(a.Errors) != 1 || !hasString(a.Errors, "other.example") {
+		t.Fatalf("unexpected errors %v", a.Errors)
+	}
+
+	a = analyze("loop.example")
+	if !hasString(a.Errors, "includes itself") {
+		t.Fatalf("expected loop error, got %v", a.Errors)
+	}
+
+	a = analyze("void.example")
+	if a.VoidLookups != 3 || !hasString(a.Errors, "maximum of 2") {
+		t.Fatalf("got voidlookups %d, errors %v", a.VoidLookups, a.Errors)
+	}
+
+	a = analyze("many.example")
+	if a.DNSLookups != 11 || !hasString(a.Errors, fmt.Sprintf("maximum of %d", dnsRequestsMax)) {
+		t.Fatalf("got dnslookups %d, errors %v", a.DNSLookups, a.Errors)
+	}
+
+	a = analyze("afterall.example")
+	if !hasString(a.Warnings, "never evaluated") {
+		t.Fatalf("expected warning for mechanism after all, got %v", a.Warnings)
+	}
+
+	a = analyze("multiple.example")
+	if a.Record.Error == "" || len(a.Errors) != 1 {
+		t.Fatalf("expected error for multiple records, got %v", a.Errors)
+	}
+
+	if _, err := Analyze(context.Background(), pkglog.Logger, resolver, Args{}); err == nil {
+		t.Fatalf("expected error for analysis without domain")
+	}
+}
@@ -35,6 +35,7 @@ import (
 	"io"
 	"io/fs"
 	"log/slog"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -78,6 +79,11 @@ var (
 	ErrAccountUnknown     = errors.New("no such account")
 	ErrOverQuota          = errors.New("account over quota")
 	ErrLoginDisabled      = errors.New("login disabled for account")
+	ErrLoginLocked        = errors.New("login temporarily locked after too many failed attempts")
+	ErrPasswordExpired    = errors.New("password expired, set a new password with the account web interface")
+	ErrTOTPRequired       = errors.New("two-factor authentication code required")
+	ErrTwoFactorSetup     = errors.New("two-factor authentication required, set it up with the account web interface")
+	ErrAppPassword        = errors.New("two-factor authentication enabled, login with an app password")
 )
 
 var DefaultInitialMailboxes = config.InitialMailboxes{
@@ -158,6 +164,9 @@ type Password struct {
 	CRAMMD5     CRAMMD5 // For SASL CRAM-MD5.
 	SCRAMSHA1   SCRAM   // For SASL SCRAM-SHA-1.
 	SCRAMSHA256 SCRAM   // For SASL SCRAM-SHA-256.
+
+	Changed    time.Time // When the password was set. Zero for passwords set with older versions.
+	MustChange bool      // If set, the password has expired, e.g. because the admin required a change.
 }
 
 // Subjectpass holds the secret key used to sign subjectpass tokens.
@@ -399,6 +408,80 @@ const (
 	ValidationNone      Validation = 10 // E.g. No records.
 )
 
+// MessageAuth holds the results of the authentication checks done during delivery
+// over SMTP, as also added to the message in the Authentication-Results header.
+// Stored with the message so they can be used without parsing headers. Domains are
+// unicode strings.
+type MessageAuth struct {
+	IPRev          string // Reverse IP lookup: "pass", "fail", "temperror", "permerror".
+	IPRevAuthentic bool   // Whether the lookups were DNSSEC-verified.
+
+	SPF          string // "pass", "fail", "softfail", "neutral", "none", "temperror", "permerror".
+	SPFIdentity  string // "mailfrom" or "helo". Empty if the EHLO hostname was an IP address.
+	SPFDomain    string // Domain the SPF record was evaluated for.
+	SPFAuthentic bool
+
+	DKIM []MessageAuthDKIM // One per DKIM-Signature header. Empty if there were none.
+
+	DMARC          string   // "pass", "fail", "none", "temperror", "permerror".
+	DMARCDomain    string   // Domain of message From header. Empty if there was no From address.
+	DMARCPolicy    string   // Published policy: "none", "quarantine", "reject". Empty without record.
+	DMARCAuthentic bool     // Whether the DMARC record was DNSSEC-verified.
+	DMARCOverrides []string // Local policy overrides, e.g. "mailing_list", "sampled_out", "trusted_forwarder".
+
+	// For a DMARC failure overridden because the message was forwarded by a trusted ARC
+	// sealer, the domain of the sealer.
+	ARCSealer string
+
+	BIMI       string // For messages passing DMARC, e.g. "pass", "none", "fail". Empty if not evaluated.
+	BIMIDomain string // Domain of the BIMI record, can be the organizational domain of DMARCDomain.
+
+	// DNS blocklists the remote IP was checked against. Blocklists are only checked
+	// during junk analysis for senders without reputation.
+	DNSBLs []MessageAuthDNSBL
+}
+
+// MessageAuthDKIM is the verification result for a DKIM-Signature header.
+type MessageAuthDKIM struct {
+	Status    string // "pass", "fail", "neutral", "none", "policy", "temperror", "permerror".
+	Domain    string // Signing domain, empty if the signature could not be parsed.
+	Selector  string
+	Algorithm string // E.g. "rsa-sha256" or "ed25519-sha256".
+	Identity  string // Optional agent or user identifier, "i=" in the signature.
+	Authentic bool   // Whether the DKIM DNS record was DNSSEC-verified.
+	Error     string // Verification error, if any.
+}
+
+// MessageAuthDNSBL is the result of a DNS blocklist check of the remote IP.
+type MessageAuthDNSBL struct {
+	Zone   string
+	Status string // "pass" for not listed, "fail" for listed, "temperror", or "skipped" if the blocklist is unhealthy.
+}
+
+// MessageJunkVerdict is the outcome of the junk analysis during delivery over
+// SMTP, with the signals that contributed to it. Stored with the message to
+// explain why it was delivered to the Junk or Rejects mailbox, or accepted.
+type MessageJunkVerdict struct {
+	Accept  bool     // Whether the analysis accepted the message. Rejected messages are stored in the Rejects mailbox.
+	Reason  string   // Reason for the decision, as in the X-Mox-Reason header, e.g. "junk-content", "dmarc-policy", "no-bad-signals" or a reputation method like "msgfromfull".
+	Details []string // Human-readable descriptions of the evaluated signals.
+	Mailbox string   // Mailbox selected by the analysis, e.g. Junk for quarantined messages. Empty for the mailbox of the destination.
+
+	Classified  bool    // Whether the junk filter classified the message, not done for senders with a conclusive reputation. The fields below are only set if so.
+	Score       float64 // Spam probability, between 0 (ham) and 1 (spam). Combined with the shared junk filter of the domain, if configured.
+	Threshold   float64 // Messages with a higher score are junk. Can be stricter than configured, e.g. for connections without TLS.
+	Significant bool    // Whether enough known words were found for the score to be used.
+	HamWords    []MessageJunkWord
+	SpamWords   []MessageJunkWord
+}
+
+// MessageJunkWord is a word (or word combination) that contributed to the score
+// of the junk filter.
+type MessageJunkWord struct {
+	Word  string
+	Score float64 // Spaminess, 0 is ham, 1 is spam.
+}
+
 // Message stored in database and per-message file on disk.
 //
 // Contents are always the combined data from MsgPrefix and the on-disk file named
@@ -519,6 +602,20 @@ type Message struct {
 	OrigEHLODomain  string
 	OrigDKIMDomains []string
 
+	// Results of authentication checks during delivery over SMTP, nil for messages
+	// not delivered over SMTP (e.g. sent, imported), or delivered before the results
+	// were stored.
+	Auth *MessageAuth
+
+	// Outcome of the junk analysis during delivery over SMTP, nil for messages not
+	// delivered over SMTP, or delivered before verdicts were stored. Not included in
+	// JSON because of its size, the webmail and admin APIs have separate functions.
+	JunkVerdict *MessageJunkVerdict `json:"-"`
+
+	// Junk status of a message delivered over SMTP as last accounted for in the
+	// server-wide sender reputation. Nil if no junk status was set yet.
+	ReputationJunk *bool
+
 	// Canonicalized Message-Id, always lower-case and normalized quoting, without
 	// <>'s. Empty if missing. Used for matching message threads, and to prevent
 	// duplicate reject delivery.
@@ -577,6 +674,16 @@ type Message struct {
 	TrainedJunk *bool  // If nil, no training done yet. Otherwise, true is trained as junk, false trained as nonjunk.
 	MsgPrefix   []byte // Typically holds received headers and/or header separator.
 
+	// Size of the on-disk message file, excluding MsgPrefix. Smaller than Size minus
+	// the length of MsgPrefix if the file is compressed. Zero if the message was added
+	// while MessageCompression wasn't configured, and the message hasn't been
+	// compressed yet.
+	StoredSize int64
+
+	// Whether the on-disk message file is compressed. Never determined from the file
+	// contents, a message can contain anything.
+	Compressed bool
+
 	// If non-nil, a preview of the message based on text and/or html parts of the
 	// message. Used in the webmail and IMAP PREVIEW extension. If non-nil, it is empty
 	// if no preview could be created, or the message has not textual content or
@@ -757,6 +864,20 @@ func (m *Message) JunkFlagsForMailbox(mb Mailbox, conf config.Account) {
 	}
 }
 
+// JunkFlagsForMove sets Junk and Notjunk flags for a message moved from mbSrc to
+// mbDst. Like JunkFlagsForMailbox, but when automatic junk flags are not enabled,
+// a message moved out of the Junk mailbox to a mailbox other than Trash is marked
+// as not junk. So moving to Junk trains the message as spam, and moving it back
+// trains it as ham, regardless of whether the move is done through IMAP, webmail
+// or the webapi.
+func (m *Message) JunkFlagsForMove(mbSrc, mbDst Mailbox, conf config.Account) {
+	m.JunkFlagsForMailbox(mbDst, conf)
+	if !conf.AutomaticJunkFlags.Enabled && mbSrc.Junk && !mbDst.Junk && !mbDst.Trash {
+		m.Junk = false
+		m.Notjunk = true
+	}
+}
+
 // Recipient represents the recipient of a message. It is tracked to allow
 // first-time incoming replies from users this account has sent messages to. When a
 // mailbox is added to the Sent mailbox the message is parsed and recipients are
@@ -794,6 +915,14 @@ type RecipientDomainTLS struct {
 type DiskUsage struct {
 	ID          int64 // Always one record with ID 1.
 	MessageSize int64 // Sum of all messages, for quota accounting.
+
+	// When the account went over its soft limit, starting the grace period. Zero
+	// if not over the soft limit.
+	SoftLimitExceeded time.Time
+
+	// Highest percentage of QuotaWarnPercentages a warning message was delivered
+	// for. Lowered when usage drops, so a warning is delivered again.
+	QuotaWarned int
 }
 
 // SessionToken and CSRFToken are types to prevent mixing them up.
@@ -811,6 +940,16 @@ type LoginSession struct {
 	CSRFTokenBinary    [16]byte  // For API requests, in "x-mox-csrf" header.
 	AccountName        string    `bstore:"nonzero"`
 	LoginAddress       string    `bstore:"nonzero"`
+	Kind               string    // Web interface logged into, "webmail" or "webaccount".
+	RemoteIP           string    // Of login.
+	UserAgent          string    // Of login.
+
+	// For sessions of an admin acting as the account, e.g. for troubleshooting. The
+	// admin as in AuditEvent.Actor, e.g. "(admin)" or "(admin:<user>)". Such sessions
+	// end at ImpersonationEnd, even when used.
+	ImpersonatedBy   string
+	ImpersonationEnd time.Time
+	ReadOnly         bool // No changes can be made through the session.
 
 	// Set when loading from database.
 	sessionToken SessionToken
@@ -845,6 +984,44 @@ type Settings struct {
 
 	// Additional headers to display in message view. E.g. Delivered-To, User-Agent, X-Mox-Reason.
 	ShowHeaders []string
+
+	// Number of seconds, between 5 and 30, that messages sent from webmail are held
+	// in the queue before delivery, during which sending can be undone. Zero
+	// disables.
+	UndoSendSeconds int
+
+	// HTML elements and attributes allowed in messages composed with HTML in webmail,
+	// and in HTML messages displayed in webmail, e.g. "p", "a" and "href". Others are
+	// removed. If both are empty, a default policy is used for composed messages, and
+	// displayed messages only have scripts and event handlers removed. If one is
+	// empty, its default is used.
+	HTMLAllowedTags       []string
+	HTMLAllowedAttributes []string
+
+	// Load remote content, such as images, in HTML messages through a proxy in mox,
+	// so senders don't see the IP address and user-agent of the user. Tracking
+	// parameters are removed from the URLs.
+	RemoteContentProxy bool
+
+	// Labels with display settings, in display order. Labels are keywords on
+	// messages. Changed through dedicated webmail API calls, not by saving settings.
+	Labels []Label
+
+	// Mailboxes, by name, for which new messages cause a Web Push notification to
+	// browsers that subscribed through the webmail. If empty, only the Inbox.
+	NotifyMailboxes []string
+
+	// Senders for which new messages cause a Web Push notification, as email address
+	// or as domain starting with "@". If empty, messages from all senders cause a
+	// notification.
+	NotifySenders []string
+}
+
+// Label is a message keyword with display settings for the webmail. A label name
+// with a slash is shown below the label before the slash, e.g. "work/project".
+type Label struct {
+	Name  string // Keyword, lower case.
+	Color string // Optional, "#rrggbb".
 }
 
 // ViewMode how a message should be viewed: its text parts, html parts, or html
@@ -863,6 +1040,198 @@ type FromAddressSettings struct {
 	ViewMode    ViewMode
 }
 
+// SMIMEPin is the S/MIME key seen for a From address. The webmail warns when a
+// message from the address is signed with another key.
+type SMIMEPin struct {
+	FromAddress string    // Unicode.
+	Fingerprint string    `bstore:"nonzero"` // Raw-url-base64-encoded SHA-256 of the Subject Public Key Info of the certificate.
+	Updated     time.Time `bstore:"default now"`
+}
+
+// Template is a message template of the account, for composing messages in the
+// webmail, e.g. a canned response. The subject and text can contain placeholders,
+// like those of config.MessageTemplate, that are replaced when the template is
+// used. Templates shared by all accounts of a domain are in the domain
+// configuration.
+type Template struct {
+	ID          int64
+	Name        string `bstore:"nonzero,unique"`
+	Subject     string // If empty, the subject in the compose window is left as is.
+	Text        string
+	Attachments []TemplateAttachment
+}
+
+// TemplateAttachment is a file added as attachment when composing a message with
+// a template.
+type TemplateAttachment struct {
+	Filename string
+	DataURI  string // Full data of the attachment, with base64 encoding and including content-type.
+}
+
+// PGPKey is an OpenPGP key of the account, used in the webmail for verifying,
+// decrypting, signing and encrypting messages. Keys with a private key are the
+// account's own keys. Public keys of others are stored to encrypt messages to and
+// verify messages from them.
+type PGPKey struct {
+	ID          int64
+	Created     time.Time `bstore:"default now"`
+	Fingerprint string    `bstore:"nonzero,unique"` // Upper case hex.
+	KeyID       string    // Upper case hex, last 16 characters of fingerprint.
+	Addresses   []string  // Email addresses in user IDs of the key, unicode.
+	PublicKey   string    `bstore:"nonzero"` // Armored public key.
+	PrivateKey  string    // Armored private key, possibly encrypted with a passphrase. Empty for keys of others.
+
+	// Whether the public key is served in the OpenPGP Web Key Directory for the
+	// addresses of the key that belong to the account. Only for keys with a private
+	// key.
+	Publish bool
+}
+
+// Calendar is a collection of calendar objects of the account, served over
+// CalDAV. The scheduling inbox and outbox are calendars with names "inbox" and
+// "outbox". The scheduling inbox holds meeting invitations and replies received by
+// email.
+type Calendar struct {
+	ID          int64
+	Created     time.Time `bstore:"default now"`
+	Name        string    `bstore:"nonzero,unique"` // Path element in URL.
+	DisplayName string
+	Description string
+	Color       string // As set by clients, e.g. "#0077ccff".
+
+	// Incremented for each change to the calendar or its objects, used as CTag by
+	// clients to detect changes.
+	ModSeq int64
+}
+
+// CalendarObject is an iCalendar resource in a calendar, typically an event or
+// task, possibly with multiple components for instances of a recurring event.
+type CalendarObject struct {
+	ID         int64
+	CalendarID int64     `bstore:"nonzero,ref Calendar,unique CalendarID+Name"`
+	Name       string    `bstore:"nonzero"` // Path element in URL, typically ending in ".ics".
+	UID        string    `bstore:"index"`   // Shared by the components in Data.
+	Modified   time.Time `bstore:"default now"`
+	ETag       string    // Hash of Data, without quotes.
+	Data       string    // iCalendar VCALENDAR object.
+}
+
+// AddressBook is a collection of contacts of the account, served over CardDAV.
+// The address book with name "collected" holds addresses that messages were sent
+// to, see ContactCollectName.
+type AddressBook struct {
+	ID          int64
+	Created     time.Time `bstore:"default now"`
+	Name        string    `bstore:"nonzero,unique"` // Path element in URL.
+	DisplayName string
+	Description string
+
+	// Incremented for each change to the address book or its contacts, used as CTag
+	// by clients to detect changes.
+	ModSeq int64
+}
+
+// Contact is a vCard resource in an address book.
+type Contact struct {
+	ID            int64
+	AddressBookID int64     `bstore:"nonzero,ref AddressBook,unique AddressBookID+Name"`
+	Name          string    `bstore:"nonzero"` // Path element in URL, typically ending in ".vcf".
+	UID           string    `bstore:"index"`
+	Modified      time.Time `bstore:"default now"`
+	ETag          string    // Hash of Data, without quotes.
+	Data          string    // vCard.
+
+	// From the vCard, for autocompleting recipients.
+	FullName string
+	Emails   []string // Lower case.
+}
+
+// Unsubscribe is an unsubscribe request made through the webmail for a message
+// with a List-Unsubscribe header, kept as history per sender.
+type Unsubscribe struct {
+	ID          int64
+	Time        time.Time `bstore:"default now"`
+	FromAddress string    `bstore:"nonzero,index"` // Lower case address from the From header of the message.
+	ListID      string    // From List-Id header, may be empty.
+	MessageID   int64     // Message the request was made for, may have been removed since.
+	Method      string    // "oneclick" for an HTTPS POST, or "mailto" for a message.
+	Target      string    // URL or email address.
+	Error       string    // If the request failed.
+}
+
+// Rule is a filter rule managed through the webmail, applied to incoming
+// messages after delivery, and on request to existing messages in a mailbox.
+// All non-empty conditions must match for the actions to be applied. Text
+// conditions are case-insensitive substring matches.
+type Rule struct {
+	ID       int64
+	Position int // Rules are evaluated in ascending position.
+	Name     string
+	Disabled bool
+
+	// Conditions.
+	From            string // Address or display name in From header.
+	To              string // Address or display name in To or Cc header.
+	SubjectContains string
+	HasAttachment   bool
+	ListID          string // In List-Id header.
+
+	// Actions.
+	Mailbox   string   // Move to mailbox with this name.
+	MarkRead  bool     // Set the \Seen flag.
+	ForwardTo []string // Forward as attachment to these addresses. Only for incoming messages.
+	Delete    bool     // Move to mailbox with Trash special-use role.
+	Stop      bool     // Don't evaluate later rules if this rule matches.
+}
+
+// SavedSearch is a named search query managed through the webmail, shown as a
+// virtual folder in the list of mailboxes.
+type SavedSearch struct {
+	ID      int64
+	Name    string `bstore:"nonzero,unique"`
+	Search  string // Search query as entered in the webmail search bar.
+	Filters string // JSON-encoded webmail filters, for evaluating the search on the server.
+}
+
+// Draft is the autosaved state of a message being composed in the webmail. The
+// version is incremented with each save, for detecting changes made from another
+// device. Drafts that haven't been saved for a while are removed.
+type Draft struct {
+	ID      int64
+	Created time.Time `bstore:"nonzero,default now"`
+	Updated time.Time `bstore:"nonzero,index"`
+	Version int64     // Incremented on each save.
+	Compose string    // JSON-encoded compose state from the webmail.
+}
+
+// DraftAttachment is an attachment uploaded for a Draft, added to the message
+// when it is sent or saved to the Drafts mailbox.
+type DraftAttachment struct {
+	ID          int64
+	DraftID     int64  `bstore:"nonzero,ref Draft"`
+	Filename    string `bstore:"nonzero"`
+	ContentType string `bstore:"nonzero"` // Including "name" parameter.
+	Data        []byte // Decoded.
+}
+
+// WebPushSubscription is a browser that subscribed through the webmail to Web
+// Push notifications (RFC 8030) about new messages.
+type WebPushSubscription struct {
+	ID        int64
+	Created   time.Time `bstore:"nonzero,default now"`
+	Endpoint  string    `bstore:"nonzero,unique"` // HTTPS URL at push service of browser.
+	P256DH    []byte    `bstore:"nonzero"`        // Public key of browser, uncompressed P-256 point.
+	Auth      []byte    `bstore:"nonzero"`        // Authentication secret from browser, 16 bytes.
+	UserAgent string    // Of browser at time of subscribing.
+}
+
+// WebPushKey is the key for authenticating to push services with VAPID (RFC
+// 8292). Singleton ID 1, generated when first needed.
+type WebPushKey struct {
+	ID         uint8
+	PrivateKey []byte // ECDSA P-256 key, PKCS#8 DER.
+}
+
 // RulesetNoListID records a user "no" response to the question of
 // creating/removing a ruleset after moving a message with list-id header from/to
 // the inbox.
@@ -925,11 +1294,35 @@ var DBTypes = []any{
 	LoginSession{},
 	Settings{},
 	FromAddressSettings{},
+	SMIMEPin{},
+	Template{},
+	PGPKey{},
+	Calendar{},
+	CalendarObject{},
+	AddressBook{},
+	Contact{},
+	Unsubscribe{},
+	Rule{},
+	SavedSearch{},
+	Draft{},
+	DraftAttachment{},
+	WebPushSubscription{},
+	WebPushKey{},
 	RulesetNoListID{},
 	RulesetNoMsgFrom{},
 	RulesetNoMailbox{},
 	Annotation{},
 	MessageErase{},
+	SearchWord{},
+	SearchWordMessage{},
+	RecoveryCode{},
+	TOTP{},
+	AppPassword{},
+	SenderListEntry{},
+	ScreeningSender{}, // Deprecated, only kept for upgrading to SenderListEntry.
+	OutgoingFailure{},
+	SubmitNetwork{},
+	SendSuspension{},
 }
 
 // Account holds the information about a user, includings mailboxes, messages, imap subscriptions.
@@ -976,11 +1369,15 @@ type Account struct {
 
 type Upgrade struct {
 	ID                  byte
-	Threads             byte // 0: None, 1: Adding MessageID's completed, 2: Adding ThreadID's completed.
-	MailboxModSeq       bool // Whether mailboxes have been assigned modseqs.
-	MailboxParentID     bool // Setting ParentID on mailboxes.
-	MailboxCounts       bool // Global flag about whether we have mailbox flags. Instead of previous per-mailbox boolean.
-	MessageParseVersion int  // If different than latest, all messages will be reparsed.
+	Threads             byte   // 0: None, 1: Adding MessageID's completed, 2: Adding ThreadID's completed.
+	MailboxModSeq       bool   // Whether mailboxes have been assigned modseqs.
+	MailboxParentID     bool   // Setting ParentID on mailboxes.
+	MailboxCounts       bool   // Global flag about whether we have mailbox flags. Instead of previous per-mailbox boolean.
+	MessageParseVersion int    // If different than latest, all messages will be reparsed.
+	SearchIndex         bool   // Whether all messages have been added to the full-text search index.
+	SearchIndexHashed   bool   // Whether the search index has hashed words, see SearchIndexHashed in mox.conf.
+	SearchIndexSalt     []byte // For hashing words in the search index, kept when rebuilding the index.
+	ScreeningSenders    bool   // Whether ScreeningSender records have been moved to SenderListEntry.
 }
 
 const MessageParseVersionLatest = 2
@@ -993,6 +1390,8 @@ var upgradeInit = Upgrade{
 	MailboxParentID:     true,
 	MailboxCounts:       true,
 	MessageParseVersion: MessageParseVersionLatest,
+	SearchIndex:         true,
+	ScreeningSenders:    true,
 }
 
 // InitialUIDValidity returns a UIDValidity used for initializing an account.
@@ -1077,6 +1476,9 @@ func removeAccount(log mlog.Log, accountName string) error {
 		if err := tlsPublicKeyRemoveForAccount(tx, accountName); err != nil {
 			return fmt.Errorf("removing tls public keys for account: %v", err)
 		}
+		if err := passkeyRemoveForAccount(tx, accountName); err != nil {
+			return fmt.Errorf("removing passkeys for account: %v", err)
+		}
 
 		if err := loginAttemptRemoveAccount(tx, accountName); err != nil {
 			return fmt.Errorf("removing historic login attempts for account: %v", err)
@@ -1175,7 +1577,7 @@ func OpenAccountDB(log mlog.Log, accountDir, accountName string) (a *Account, re
 	}
 
 	if isNew {
-		if err := initAccount(db); err != nil {
+		if err := initAccount(db, accountName); err != nil {
 			return nil, fmt.Errorf("initializing account: %v", err)
 		}
 
@@ -1247,6 +1649,7 @@ func OpenAccountDB(log mlog.Log, accountDir, accountName string) (a *Account, re
 		}
 
 		if duChanged {
+			acc.quotaTrack(&du)
 			if err := tx.Update(&du); err != nil {
 				return fmt.Errorf("saving disk usage after erasing messages: %w", err)
 			}
@@ -1507,6 +1910,13 @@ func OpenAccountDB(log mlog.Log, accountDir, accountName string) (a *Account, re
 		}
 	}
 
+	if !up.ScreeningSenders {
+		log.Debug("upgrade: moving screening allow/block lists to sender lists")
+		if err := acc.upgradeScreeningSenders(log); err != nil {
+			return nil, fmt.Errorf("upgrade: moving screening senders to sender lists: %w", err)
+		}
+	}
+
 	if up.MessageParseVersion != MessageParseVersionLatest {
 		log.Debug("upgrade: reparsing message for mime structures for new message parse version", slog.Int("current", up.MessageParseVersion), slog.Int("latest", MessageParseVersionLatest))
 
@@ -1569,6 +1979,50 @@ func OpenAccountDB(log mlog.Log, accountDir, accountName string) (a *Account, re
 		}
 	}
 
+	if up.SearchIndexHashed != mox.Conf.Static.SearchIndexHashed {
+		log.Info("clearing full-text search index for change of hashing, rebuilding in background", slog.Bool("hashed", mox.Conf.Static.SearchIndexHashed))
+		err := acc.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+			return searchIndexReset(tx, &up, mox.Conf.Static.SearchIndexHashed)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("clearing search index: %v", err)
+		}
+	}
+
+	if !up.SearchIndex {
+		log.Debug("upgrade: adding messages to full-text search index, in background")
+
+		// Increase account use before holding on to account in background.
+		// Caller holds the lock. The goroutine below decreases nused by calling
+		// closeAccount.
+		acc.nused++
+
+		go func() {
+			start := time.Now()
+
+			defer func() {
+				x := recover()
+				if x != nil {
+					log.Error("unhandled panic adding messages to search index", slog.Any("err", x))
+					debug.PrintStack()
+					metrics.PanicInc(metrics.Store)
+				}
+
+				// Our parent context/goroutine has openAccounts lock held, so we won't make
+				// progress until after the enclosing method has returned.
+				err := closeAccount(acc)
+				log.Check(err, "closing account after adding messages to search index")
+			}()
+
+			total, err := acc.upgradeSearchIndex(mox.Shutdown, log)
+			if err != nil {
+				log.Errorx("upgrade failed: adding messages to full-text search index", err, slog.Duration("duration", time.Since(start)))
+				return
+			}
+			log.Info("upgrade completed: adding messages to full-text search index", slog.Int("total", total), slog.Duration("duration", time.Since(start)))
+		}()
+	}
+
 	if up.Threads == 2 {
 		close(acc.threadsCompleted)
 		return acc, nil
@@ -1629,17 +2083,33 @@ func (a *Account) ThreadingWait(log mlog.Log) error {
 	return a.threadsErr
 }
 
-func initAccount(db *bstore.DB) error {
+func initAccount(db *bstore.DB, accountName string) error {
+	// Initial webmail settings can be configured for the domain of the account.
+	settings := Settings{ID: 1}
+	if web, ok := mox.Conf.AccountWeb(accountName); ok && web.WebmailDefaults != nil {
+		d := web.WebmailDefaults
+		settings.ShowHTML = d.ShowHTML
+		settings.ShowAddressSecurity = d.ShowAddressSecurity
+		settings.NoShowShortcuts = d.NoShowShortcuts
+		settings.RemoteContentProxy = d.RemoteContentProxy
+		settings.UndoSendSeconds = d.UndoSendSeconds
+	}
+
 	return db.Write(context.TODO(), func(tx *bstore.Tx) error {
 		uidvalidity := InitialUIDValidity()
 
-		if err := tx.Insert(&upgradeInit); err != nil {
+		up := upgradeInit
+		if mox.Conf.Static.SearchIndexHashed {
+			up.SearchIndexHashed = true
+			up.SearchIndexSalt = searchIndexSaltNew()
+		}
+		if err := tx.Insert(&up); err != nil {
 			return err
 		}
 		if err := tx.Insert(&DiskUsage{ID: 1}); err != nil {
 			return err
 		}
-		if err := tx.Insert(&Settings{ID: 1}); err != nil {
+		if err := tx.Insert(&settings); err != nil {
 			return err
 		}
 
@@ -1978,12 +2448,15 @@ func (a *Account) CheckConsistency() error {
 
 			messageIDs[m.ID] = struct{}{}
 			p := a.MessagePath(m.ID)
-			st, err := os.Stat(p)
+			size, storedSize, err := MsgFileSize(p, m.Compressed)
 			if err != nil {
 				existserr := fmt.Sprintf("message %d in mailbox %q (id %d) on-disk file %s: %v", m.ID, mb.Name, mb.ID, p, err)
 				fileErrors = append(fileErrors, existserr)
-			} else if len(fileErrors) < 20 && m.Size != int64(len(m.MsgPrefix))+st.Size() {
-				sizeerr := fmt.Sprintf("message %d in mailbox %q (id %d) has size %d != len msgprefix %d + on-disk file size %d = %d", m.ID, mb.Name, mb.ID, m.Size, len(m.MsgPrefix), st.Size(), int64(len(m.MsgPrefix))+st.Size())
+			} else if len(fileErrors) < 20 && m.Size != int64(len(m.MsgPrefix))+size {
+				sizeerr := fmt.Sprintf("message %d in mailbox %q (id %d) has size %d != len msgprefix %d + on-disk file size %d = %d", m.ID, mb.Name, mb.ID, m.Size, len(m.MsgPrefix), size, int64(len(m.MsgPrefix))+size)
+				fileErrors = append(fileErrors, sizeerr)
+			} else if len(fileErrors) < 20 && m.StoredSize != 0 && m.StoredSize != storedSize {
+				sizeerr := fmt.Sprintf("message %d in mailbox %q (id %d) has stored size %d != on-disk file size %d", m.ID, mb.Name, mb.ID, m.StoredSize, storedSize)
 				fileErrors = append(fileErrors, sizeerr)
 			}
 
@@ -2170,6 +2643,17 @@ func nextModSeq(tx *bstore.Tx) (ModSeq, error) {
 	return v.LastModSeq, tx.Update(&v)
 }
 
+// HighestModSeq returns the last assigned modification sequence, or 0 if none has
+// been assigned yet.
+func (a *Account) HighestModSeq(tx *bstore.Tx) (ModSeq, error) {
+	v := SyncState{ID: 1}
+	err := tx.Get(&v)
+	if err == bstore.ErrAbsent {
+		return 0, nil
+	}
+	return v.LastModSeq, err
+}
+
 func (a *Account) HighestDeletedModSeq(tx *bstore.Tx) (ModSeq, error) {
 	v := SyncState{ID: 1}
 	err := tx.Get(&v)
@@ -2276,14 +2760,14 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 		}
 
 		if !opts.SkipCheckQuota {
-			maxSize := a.QuotaMessageSize()
-			if maxSize > 0 && m.Size > maxSize-du.MessageSize {
-				return fmt.Errorf("%w: max size %d bytes", ErrOverQuota, maxSize)
+			if _, err := a.quotaCheck(du, m.Size); err != nil {
+				return err
 			}
 		}
 
 		if !opts.SkipUpdateDiskUsage {
 			du.MessageSize += m.Size
+			a.quotaTrack(&du)
 			if err := tx.Update(&du); err != nil {
 				return fmt.Errorf("update disk usage: %v", err)
 			}
@@ -2329,6 +2813,13 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 	conf, _ := a.Conf()
 	m.JunkFlagsForMailbox(*mb, conf)
 
+	// The junk status at delivery is not a user action, and is not accounted for in
+	// the sender reputation.
+	if m.Auth != nil && m.ReputationJunk == nil && m.Junk != m.Notjunk {
+		isJunk := m.Junk
+		m.ReputationJunk = &isJunk
+	}
+
 	var part *message.Part
 	if m.ParsedBuf == nil {
 		mr := FileMsgReader(m.MsgPrefix, msgFile) // We don't close, it would close the msgFile.
@@ -2406,6 +2897,20 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 		}
 	}
 
+	// Store the message file compressed if configured. The compressed copy is written
+	// to a temporary file, msgFile is not modified.
+	storeFile := msgFile
+	tmpFile, storedSize, err := compressTemp(log, msgFile)
+	if err != nil {
+		return fmt.Errorf("compressing message file: %v", err)
+	}
+	if tmpFile != nil {
+		defer CloseRemoveTempFile(log, tmpFile, "compressed message")
+		storeFile = tmpFile
+	}
+	m.StoredSize = storedSize
+	m.Compressed = tmpFile != nil
+
 	if err := tx.Insert(m); err != nil {
 		return fmt.Errorf("inserting message: %w", err)
 	}
@@ -2453,6 +2958,15 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 			if err := tx.Insert(&mr); err != nil {
 				return fmt.Errorf("inserting sent message recipients: %w", err)
 			}
+			if err := contactCollect(tx, addr.Name, smtp.NewAddress(lp, d)); err != nil {
+				return fmt.Errorf("collecting recipient address as contact: %w", err)
+			}
+		}
+	}
+
+	if getPart() != nil {
+		if err := searchIndexAdd(log, tx, m, part); err != nil {
+			return fmt.Errorf("adding message to search index: %w", err)
 		}
 	}
 
@@ -2468,12 +2982,12 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 
 	// Sync file data to disk.
 	if !opts.SkipSourceFileSync {
-		if err := msgFile.Sync(); err != nil {
+		if err := storeFile.Sync(); err != nil {
 			return fmt.Errorf("fsync message file: %w", err)
 		}
 	}
 
-	if err := moxio.LinkOrCopy(log, msgPath, msgFile.Name(), &moxio.AtReader{R: msgFile}, true); err != nil {
+	if err := dedupLink(log, msgPath, storeFile); err != nil {
 		return fmt.Errorf("linking/copying message to new file: %w", err)
 	}
 
@@ -2527,61 +3041,78 @@ func (a *Account) MessageAdd(log mlog.Log, tx *bstore.Tx, mb *Mailbox, m *Messag
 //
 // Callers are responsible for checking if the account has NoCustomPassword set.
 func (a *Account) SetPassword(log mlog.Log, password string) error {
+	pw, err := MakePassword(password)
+	if err != nil {
+		return err
+	}
+	return a.SetPasswordHashes(log, pw)
+}
+
+// MakePassword returns the hashes of a new password, for storing with
+// SetPasswordHashes. The password must be at least 8 characters long.
+func MakePassword(password string) (Password, error) {
 	password, err := precis.OpaqueString.String(password)
 	if err != nil {
-		return fmt.Errorf(`password not allowed by "precis"`)
+		return Password{}, fmt.Errorf(`password not allowed by "precis"`)
 	}
 
 	if len(password) < 8 {
 		// We actually check for bytes...
-		return fmt.Errorf("password must be at least 8 characters long")
+		return Password{}, fmt.Errorf("password must be at least 8 characters long")
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 	if err != nil {
-		return fmt.Errorf("generating password hash: %w", err)
-	}
+		return Password{}, fmt.Errorf("generating password hash: %w", err)
+	}
+
+	var pw Password
+	pw.Hash = string(hash)
+
+	// CRAM-MD5 calculates an HMAC-MD5, with the password as key, over a per-attempt
+	// unique text that includes a timestamp. HMAC performs two hashes. Both times, the
+	// first block is based on the key/password. We hash those first blocks now, and
+	// store the hash state in the database. When we actually authenticate, we'll
+	// complete the HMAC by hashing only the text. We cannot store crypto/hmac's hash,
+	// because it does not expose its internal state and isn't a BinaryMarshaler.
+	// ../rfc/2104:121
+	pw.CRAMMD5.Ipad = md5.New()
+	pw.CRAMMD5.Opad = md5.New()
+	key := []byte(password)
+	if len(key) > 64 {
+		t := md5.Sum(key)
+		key = t[:]
+	}
+	ipad := make([]byte, md5.BlockSize)
+	opad := make([]byte, md5.BlockSize)
+	copy(ipad, key)
+	copy(opad, key)
+	for i := range ipad {
+		ipad[i] ^= 0x36
+		opad[i] ^= 0x5c
+	}
+	pw.CRAMMD5.Ipad.Write(ipad)
+	pw.CRAMMD5.Opad.Write(opad)
+
+	pw.SCRAMSHA1.Salt = scram.MakeRandom()
+	pw.SCRAMSHA1.Iterations = 2 * 4096
+	pw.SCRAMSHA1.SaltedPassword = scram.SaltPassword(sha1.New, password, pw.SCRAMSHA1.Salt, pw.SCRAMSHA1.Iterations)
+
+	pw.SCRAMSHA256.Salt = scram.MakeRandom()
+	pw.SCRAMSHA256.Iterations = 4096
+	pw.SCRAMSHA256.SaltedPassword = scram.SaltPassword(sha256.New, password, pw.SCRAMSHA256.Salt, pw.SCRAMSHA256.Iterations)
+	pw.Changed = time.Now()
+
+	return pw, nil
+}
 
-	err = a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+// SetPasswordHashes saves a new password for this account, with hashes from
+// MakePassword, e.g. made before the account existed.
+func (a *Account) SetPasswordHashes(log mlog.Log, pw Password) error {
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
 		if _, err := bstore.QueryTx[Password](tx).Delete(); err != nil {
 			return fmt.Errorf("deleting existing password: %v", err)
 		}
-		var pw Password
-		pw.Hash = string(hash)
-
-		// CRAM-MD5 calculates an HMAC-MD5, with the password as key, over a per-attempt
-		// unique text that includes a timestamp. HMAC performs two hashes. Both times, the
-		// first block is based on the key/password. We hash those first blocks now, and
-		// store the hash state in the database. When we actually authenticate, we'll
-		// complete the HMAC by hashing only the text. We cannot store crypto/hmac's hash,
-		// because it does not expose its internal state and isn't a BinaryMarshaler.
-		// ../rfc/2104:121
-		pw.CRAMMD5.Ipad = md5.New()
-		pw.CRAMMD5.Opad = md5.New()
-		key := []byte(password)
-		if len(key) > 64 {
-			t := md5.Sum(key)
-			key = t[:]
-		}
-		ipad := make([]byte, md5.BlockSize)
-		opad := make([]byte, md5.BlockSize)
-		copy(ipad, key)
-		copy(opad, key)
-		for i := range ipad {
-			ipad[i] ^= 0x36
-			opad[i] ^= 0x5c
-		}
-		pw.CRAMMD5.Ipad.Write(ipad)
-		pw.CRAMMD5.Opad.Write(opad)
-
-		pw.SCRAMSHA1.Salt = scram.MakeRandom()
-		pw.SCRAMSHA1.Iterations = 2 * 4096
-		pw.SCRAMSHA1.SaltedPassword = scram.SaltPassword(sha1.New, password, pw.SCRAMSHA1.Salt, pw.SCRAMSHA1.Iterations)
-
-		pw.SCRAMSHA256.Salt = scram.MakeRandom()
-		pw.SCRAMSHA256.Iterations = 4096
-		pw.SCRAMSHA256.SaltedPassword = scram.SaltPassword(sha256.New, password, pw.SCRAMSHA256.Salt, pw.SCRAMSHA256.Iterations)
-
 		if err := tx.Insert(&pw); err != nil {
 			return fmt.Errorf("inserting new password: %v", err)
 		}
@@ -2908,7 +3439,7 @@ func (a *Account) MessagePath(messageID int64) string {
 // MessageReader opens a message for reading, transparently combining the
 // message prefix with the original incoming message.
 func (a *Account) MessageReader(m Message) *MsgReader {
-	return &MsgReader{prefix: m.MsgPrefix, path: a.MessagePath(m.ID), size: m.Size}
+	return &MsgReader{prefix: m.MsgPrefix, path: a.MessagePath(m.ID), compressed: m.Compressed, size: m.Size}
 }
 
 // DeliverDestination delivers an email to dest, based on the configured rulesets.
@@ -3025,6 +3556,13 @@ func (a *Account) MessageRemove(log mlog.Log, tx *bstore.Tx, modseq ModSeq, mb *
 		return ChangeRemoveUIDs{}, ChangeMailboxCounts{}, fmt.Errorf("deleting message recipients for messages: %w", err)
 	}
 
+	// Remove messages from the full-text search index.
+	qsw := bstore.QueryTx[SearchWordMessage](tx)
+	qsw.FilterEqual("MessageID", anyIDs...)
+	if _, err := qsw.Delete(); err != nil {
+		return ChangeRemoveUIDs{}, ChangeMailboxCounts{}, fmt.Errorf("removing messages from search index: %w", err)
+	}
+
 	// Loaded lazily.
 	jf := opts.JunkFilter
 
@@ -3195,6 +3733,7 @@ func (a *Account) AddMessageSize(log mlog.Log, tx *bstore.Tx, size int64) error
 	if du.MessageSize < 0 {
 		log.Error("negative total message size", slog.Int64("delta", size), slog.Int64("newtotalsize", du.MessageSize))
 	}
+	a.quotaTrack(&du)
 	if err := tx.Update(&du); err != nil {
 		return fmt.Errorf("update total message size: %v", err)
 	}
@@ -3202,10 +3741,16 @@ func (a *Account) AddMessageSize(log mlog.Log, tx *bstore.Tx, size int64) error
 }
 
 // QuotaMessageSize returns the effective maximum total message size for an
-// account. Returns 0 if there is no maximum.
+// account, from the account, its domain or the global default. Returns 0 if there
+// is no maximum.
 func (a *Account) QuotaMessageSize() int64 {
 	conf, _ := a.Conf()
 	size := conf.QuotaMessageSize
+	if size == 0 {
+		if dom, ok := mox.Conf.Domain(conf.DNSDomain); ok {
+			size = dom.QuotaMessageSize
+		}
+	}
 	if size == 0 {
 		size = mox.Conf.Static.QuotaMessageSize
 	}
@@ -3216,7 +3761,8 @@ func (a *Account) QuotaMessageSize() int64 {
 }
 
 // CanAddMessageSize checks if a message of size bytes can be added, depending on
-// total message size and configured quota for account.
+// total message size and configured quota and soft limit for account. If the
+// message cannot be added, maxSize is the limit that would be exceeded.
 func (a *Account) CanAddMessageSize(tx *bstore.Tx, size int64) (ok bool, maxSize int64, err error) {
 	maxSize = a.QuotaMessageSize()
 	if maxSize <= 0 {
@@ -3227,7 +3773,11 @@ func (a *Account) CanAddMessageSize(tx *bstore.Tx, size int64) (ok bool, maxSize
 	if err := tx.Get(&du); err != nil {
 		return false, maxSize, fmt.Errorf("get diskusage: %v", err)
 	}
-	return du.MessageSize+size <= maxSize, maxSize, nil
+	maxSize, err = a.quotaCheck(du, size)
+	if err != nil {
+		return false, maxSize, nil
+	}
+	return true, maxSize, nil
 }
 
 // We keep a cache of recent successful authentications, so we don't have to bcrypt successful calls each time.
@@ -3256,16 +3806,47 @@ func manageAuthCache() {
 	}
 }
 
+// Authenticator verifies credentials with an external authentication backend,
+// such as an LDAP directory.
+type Authenticator interface {
+	// Authenticate verifies password for login, typically an email address, but
+	// possibly a user name known to the backend. For valid credentials, the name of
+	// the account to open is returned, which the authenticator may have just created.
+	// For invalid credentials, ErrUnknownCredentials is returned.
+	Authenticate(ctx context.Context, log mlog.Log, login, password string) (accountName string, rerr error)
+}
+
+// ExternalAuth, if set, verifies passwords in OpenEmailAuth for logins that don't
+// match an address of an account, and for accounts when the password doesn't
+// match the account password or an app password. Set during startup.
+var ExternalAuth Authenticator
+
 // OpenEmailAuth opens an account given an email address and password.
 //
 // The email address may contain a catchall separator.
 // For invalid credentials, a nil account is returned, but accName may be
 // non-empty.
-func OpenEmailAuth(log mlog.Log, email string, password string, checkLoginDisabled bool) (racc *Account, raccName string, rerr error) {
+//
+// If ExternalAuth is set, it is used to verify the password too. It can also
+// authenticate logins that aren't an address of an account.
+//
+// Protocol is empty for logins to the web interfaces, which only accept the
+// account password and verify a second factor separately. For other protocols,
+// e.g. "imap", app passwords of the account valid for the protocol and remoteIP
+// are also accepted. If two-factor authentication is enabled or required for the account,
+// ErrAppPassword is returned for the account password with other protocols.
+//
+// ErrLoginLocked is returned, before verifying the password, if login attempts for
+// the account are locked after too many failures. ErrPasswordExpired is returned
+// for an expired account password. If checkLoginDisabled is set, ErrLoginDisabled
+// is returned for accounts with disabled logins, after verifying the password.
+func OpenEmailAuth(log mlog.Log, email, password, protocol string, remoteIP net.IP, checkLoginDisabled bool) (racc *Account, raccName string, rerr error) {
 	// We check for LoginDisabled after verifying the password. Otherwise users can get
 	// messages about the account being disabled without knowing the password.
 	acc, accName, _, err := OpenEmail(log, email, false)
-	if err != nil {
+	if errors.Is(err, ErrUnknownCredentials) && ExternalAuth != nil {
+		return openExternalAuth(log, email, password, protocol, checkLoginDisabled)
+	} else if err != nil {
 		return nil, "", err
 	}
 
@@ -3277,37 +3858,118 @@ func OpenEmailAuth(log mlog.Log, email string, password string, checkLoginDisabl
 		}
 	}()
 
+	if err := CheckLoginLockout(accName); err != nil {
+		return nil, accName, err
+	}
+
 	password, err = precis.OpaqueString.String(password)
 	if err != nil {
-		return nil, "", ErrUnknownCredentials
+		return nil, accName, ErrUnknownCredentials
 	}
 
 	pw, err := bstore.QueryDB[Password](context.TODO(), acc.DB).Get()
-	if err != nil {
-		if err == bstore.ErrAbsent {
-			return nil, "", ErrUnknownCredentials
-		}
+	if err != nil && err != bstore.ErrAbsent {
 		return nil, "", fmt.Errorf("looking up password: %v", err)
 	}
-	authCache.Lock()
-	ok := len(password) >= 8 && authCache.success[authKey{email, pw.Hash}] == password
-	authCache.Unlock()
-	if !ok {
-		if err := bcrypt.CompareHashAndPassword([]byte(pw.Hash), []byte(password)); err != nil {
-			return nil, "", ErrUnknownCredentials
+	accountPassword := err == nil
+	if accountPassword {
+		authCache.Lock()
+		ok := len(password) >= 8 && authCache.success[authKey{email, pw.Hash}] == password
+		authCache.Unlock()
+		if !ok {
+			accountPassword = bcrypt.CompareHashAndPassword([]byte(pw.Hash), []byte(password)) == nil
+		}
+	}
+	var externalPassword bool
+	if !accountPassword {
+		var ok bool
+		if protocol != "" {
+			if ok, err = acc.appPasswordMatch(email, password, protocol, remoteIP); err != nil {
+				return nil, "", err
+			}
+		}
+		if !ok && ExternalAuth != nil {
+			if name, err := ExternalAuth.Authenticate(context.TODO(), log, email, password); err != nil && !errors.Is(err, ErrUnknownCredentials) {
+				return nil, "", fmt.Errorf("external authentication: %w", err)
+			} else if err == nil && name != accName {
+				log.Info("external authentication returned other account than configured for address", slog.String("address", email), slog.String("account", accName), slog.String("externalaccount", name))
+			} else {
+				externalPassword = err == nil
+				ok = externalPassword
+			}
+		}
+		if !ok {
+			return nil, accName, ErrUnknownCredentials
+		}
+	}
+	conf, aok := acc.Conf()
+	if !aok {
+		return nil, "", fmt.Errorf("cannot find config for account")
+	} else if checkLoginDisabled && conf.LoginDisabled != "" {
+		return nil, "", fmt.Errorf("%w: %s", ErrLoginDisabled, conf.LoginDisabled)
+	}
+	if externalPassword && protocol != "" {
+		if required, err := acc.appPasswordRequired(conf); err != nil {
+			return nil, "", err
+		} else if required {
+			return nil, accName, ErrAppPassword
 		}
 	}
-	if checkLoginDisabled {
-		conf, aok := acc.Conf()
-		if !aok {
-			return nil, "", fmt.Errorf("cannot find config for account")
-		} else if conf.LoginDisabled != "" {
-			return nil, "", fmt.Errorf("%w: %s", ErrLoginDisabled, conf.LoginDisabled)
+	if accountPassword {
+		if pw.Expired(conf, time.Now()) {
+			return nil, accName, ErrPasswordExpired
+		}
+		if protocol != "" {
+			if required, err := acc.appPasswordRequired(conf); err != nil {
+				return nil, "", err
+			} else if required {
+				return nil, accName, ErrAppPassword
+			}
+		}
+		authCache.Lock()
+		authCache.success[authKey{email, pw.Hash}] = password
+		authCache.Unlock()
+	}
+	return acc, accName, nil
+}
+
+// openExternalAuth opens the account for a login that isn't an address of an
+// account, after ExternalAuth verified the password. Like OpenEmailAuth.
+func openExternalAuth(log mlog.Log, login, password, protocol string, checkLoginDisabled bool) (racc *Account, raccName string, rerr error) {
+	password, err := precis.OpaqueString.String(password)
+	if err != nil {
+		return nil, "", ErrUnknownCredentials
+	}
+	accName, err := ExternalAuth.Authenticate(context.TODO(), log, login, password)
+	if err != nil {
+		return nil, "", err
+	}
+	if err := CheckLoginLockout(accName); err != nil {
+		return nil, accName, err
+	}
+	acc, err := OpenAccount(log, accName, false)
+	if err != nil {
+		return nil, accName, err
+	}
+	defer func() {
+		if rerr != nil {
+			err := acc.Close()
+			log.Check(err, "closing account after open auth failure")
+		}
+	}()
+	conf, ok := acc.Conf()
+	if !ok {
+		return nil, "", fmt.Errorf("cannot find config for account")
+	} else if checkLoginDisabled && conf.LoginDisabled != "" {
+		return nil, "", fmt.Errorf("%w: %s", ErrLoginDisabled, conf.LoginDisabled)
+	}
+	if protocol != "" {
+		if required, err := acc.appPasswordRequired(conf); err != nil {
+			return nil, "", err
+		} else if required {
+			return nil, accName, ErrAppPassword
 		}
 	}
-	authCache.Lock()
-	authCache.success[authKey{email, pw.Hash}] = password
-	authCache.Unlock()
 	return acc, accName, nil
 }
 
@@ -3552,7 +4214,13 @@ func CheckKeyword(kw string) error {
 // To limit damage to the internet and our reputation in case of account
 // compromise, we limit the max number of messages sent in a 24 hour window, both
 // total number of messages and number of first-time recipients.
+//
+// No limits apply to accounts exempted from rate limits in the configuration.
 func (a *Account) SendLimitReached(tx *bstore.Tx, recipients []smtp.Path) (msglimit, rcptlimit int, rerr error) {
+	if mox.Exempted(nil, a.Name).RateLimits {
+		return -1, -1, nil
+	}
+
 	conf, _ := a.Conf()
 	msgmax := conf.MaxOutgoingMessagesPerDay
 	if msgmax == 0 {
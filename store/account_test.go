@@ -239,30 +239,30 @@ func TestMailbox(t *testing.T) {
 
 	// Run the auth tests twice for possible cache effects.
 	for range 2 {
-		_, _, err := OpenEmailAuth(log, "mjl@mox.example", "bogus", false)
+		_, _, err := OpenEmailAuth(log, "mjl@mox.example", "bogus", "", nil, false)
 		if err != ErrUnknownCredentials {
 			t.Fatalf("got %v, expected ErrUnknownCredentials", err)
 		}
 	}
 
 	for range 2 {
-		acc2, _, err := OpenEmailAuth(log, "mjl@mox.example", "testtest", false)
+		acc2, _, err := OpenEmailAuth(log, "mjl@mox.example", "testtest", "", nil, false)
 		tcheck(t, err, "open for email with auth")
 		err = acc2.Close()
 		tcheck(t, err, "close account")
 	}
 
-	acc2, _, err := OpenEmailAuth(log, "other@mox.example", "testtest", false)
+	acc2, _, err := OpenEmailAuth(log, "other@mox.example", "testtest", "", nil, false)
 	tcheck(t, err, "open for email with auth")
 	err = acc2.Close()
 	tcheck(t, err, "close account")
 
-	_, _, err = OpenEmailAuth(log, "bogus@mox.example", "testtest", false)
+	_, _, err = OpenEmailAuth(log, "bogus@mox.example", "testtest", "", nil, false)
 	if err != ErrUnknownCredentials {
 		t.Fatalf("got %v, expected ErrUnknownCredentials", err)
 	}
 
-	_, _, err = OpenEmailAuth(log, "mjl@test.example", "testtest", false)
+	_, _, err = OpenEmailAuth(log, "mjl@test.example", "testtest", "", nil, false)
 	if err != ErrUnknownCredentials {
 		t.Fatalf("got %v, expected ErrUnknownCredentials", err)
 	}
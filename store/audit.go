@@ -0,0 +1,189 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+// Kinds of audit events.
+const (
+	AuditLogin    = "login"    // Login attempt, successful or failed.
+	AuditPassword = "password" // Change of password or other credentials.
+	AuditSettings = "settings" // Change of account settings by the account.
+	AuditConfig   = "config"   // Change of configuration by an admin.
+	AuditAccount  = "account"  // Account added or removed.
+	AuditExport   = "export"   // Export of messages.
+	AuditQueue    = "queue"    // Change of messages or webhooks in the queue.
+
+	AuditImpersonate = "impersonate" // Start of, and calls during, an admin acting as an account.
+)
+
+// AuditEvent is a security-relevant event, such as a login or configuration
+// change. Events are only added, and removed after the retention period
+// configured in mox.conf.
+type AuditEvent struct {
+	ID   int64
+	Time time.Time `bstore:"nonzero,default now,index"`
+	Kind string    `bstore:"nonzero,index Kind+Time"` // See AuditLogin and friends.
+
+	// Account the event applies to, empty if none.
+	Account string `bstore:"index Account+Time"`
+
+	// Who caused the event: an account name, "(admin)", or "(admin:<user>)" for
+	// admin users, "(ctl)" for commands through the ctl socket, or empty for the
+	// system itself.
+	Actor string
+
+	Protocol string // E.g. "imap", "submission", "webmail", "webaccount", "webadmin", "ctl".
+	RemoteIP string
+	Action   string // E.g. name of API method or ctl command, or authentication mechanism for logins.
+	Result   string // "ok", or an error or reason for failure.
+	Details  string // Additional information, e.g. login address.
+}
+
+// AuditFilter filters audit events. Empty fields are ignored.
+type AuditFilter struct {
+	Kind    string
+	Account string
+	Actor   string
+	Start   *time.Time // Inclusive.
+	End     *time.Time // Exclusive.
+}
+
+func auditEnabled() bool {
+	return mox.Conf.Static.AuditLog == nil || !mox.Conf.Static.AuditLog.Disabled
+}
+
+func auditRetention() time.Duration {
+	if mox.Conf.Static.AuditLog != nil && mox.Conf.Static.AuditLog.Retention > 0 {
+		return mox.Conf.Static.AuditLog.Retention
+	}
+	return 365 * 24 * time.Hour
+}
+
+// AuditAdd records an event in the audit log, unless disabled in mox.conf. Errors
+// are logged, not returned: failing to record an event should not fail the
+// operation.
+func AuditAdd(ctx context.Context, log mlog.Log, e AuditEvent) {
+	if !auditEnabled() {
+		return
+	}
+	e.ID = 0
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	err := AuthDB.Insert(ctx, &e)
+	log.Check(err, "adding audit event", slog.String("kind", e.Kind), slog.String("action", e.Action))
+}
+
+// auditLoginEvent returns the audit event for a login attempt.
+func auditLoginEvent(a LoginAttempt) AuditEvent {
+	account := a.AccountName
+	if account == "-" {
+		account = ""
+	}
+	return AuditEvent{
+		Time:     time.Now(),
+		Kind:     AuditLogin,
+		Account:  account,
+		Actor:    account,
+		Protocol: a.Protocol,
+		RemoteIP: a.RemoteIP,
+		Action:   a.AuthMech,
+		Result:   string(a.Result),
+		Details:  a.LoginAddress,
+	}
+}
+
+func auditQuery(ctx context.Context, filter AuditFilter) *bstore.Query[AuditEvent] {
+	q := bstore.QueryDB[AuditEvent](ctx, AuthDB)
+	if filter.Kind != "" || filter.Account != "" || filter.Actor != "" {
+		q.FilterNonzero(AuditEvent{Kind: filter.Kind, Account: filter.Account, Actor: filter.Actor})
+	}
+	if filter.Start != nil {
+		q.FilterGreaterEqual("Time", *filter.Start)
+	}
+	if filter.End != nil {
+		q.FilterLess("Time", *filter.End)
+	}
+	return q
+}
+
+// AuditList returns audit events matching filter, most recent first. If limit is
+// greater than 0, at most limit events are returned.
+func AuditList(ctx context.Context, filter AuditFilter, limit int) ([]AuditEvent, error) {
+	q := auditQuery(ctx, filter)
+	q.SortDesc("Time")
+	if limit > 0 {
+		q.Limit(limit)
+	}
+	return q.List()
+}
+
+// AuditForEach calls fn for each audit event matching filter, oldest first, e.g.
+// for exporting.
+func AuditForEach(ctx context.Context, filter AuditFilter, fn func(e AuditEvent) error) error {
+	q := auditQuery(ctx, filter)
+	q.SortAsc("Time")
+	return q.ForEach(fn)
+}
+
+// AuditCleanup removes audit events older than the retention period.
+func AuditCleanup(ctx context.Context) error {
+	q := bstore.QueryDB[AuditEvent](ctx, AuthDB)
+	q.FilterLess("Time", time.Now().Add(-auditRetention()))
+	_, err := q.Delete()
+	return err
+}
+
+// Syslog returns the event as syslog message, RFC 5424, without trailing newline.
+// The facility is "authpriv". Failed logins have severity "warning", other events
+// "notice".
+func (e AuditEvent) Syslog(hostname string) string {
+	severity := 5 // notice
+	if e.Result != "ok" {
+		severity = 4 // warning
+	}
+	const facility = 10 // authpriv
+	if hostname == "" {
+		hostname = "-"
+	}
+
+	// Structured data, with a private ID with the enterprise number reserved for
+	// documentation, RFC 5612.
+	var sd strings.Builder
+	sd.WriteString("[audit@32473")
+	for _, kv := range [][2]string{
+		{"id", fmt.Sprintf("%d", e.ID)},
+		{"account", e.Account},
+		{"actor", e.Actor},
+		{"protocol", e.Protocol},
+		{"remoteip", e.RemoteIP},
+		{"action", e.Action},
+		{"result", e.Result},
+	} {
+		if kv[1] == "" {
+			continue
+		}
+		// Param values must have '"', '\' and ']' escaped, RFC 5424 section 6.3.3.
+		v := strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`).Replace(kv[1])
+		fmt.Fprintf(&sd, ` %s="%s"`, kv[0], v)
+	}
+	sd.WriteString("]")
+
+	msg := e.Kind + " " + e.Action + ": " + e.Result
+	if e.Details != "" {
+		msg += ", " + e.Details
+	}
+	msg = strings.NewReplacer("\r", " ", "\n", " ").Replace(msg)
+
+	return fmt.Sprintf("<%d>1 %s %s mox - %s %s %s", facility*8+severity, e.Time.UTC().Format(time.RFC3339Nano), hostname, e.Kind, sd.String(), msg)
+}
@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestAudit(t *testing.T) {
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+
+	xctx, xcancel := context.WithCancel(ctxbg)
+	defer xcancel()
+	err := Init(xctx)
+	tcheck(t, err, "store init")
+	stopc := make(chan struct{})
+	writeLoginAttemptStop <- stopc
+	<-stopc
+	defer func() {
+		go func() {
+			c := <-writeLoginAttemptStop
+			c <- struct{}{}
+		}()
+
+		err := Close()
+		tcheck(t, err, "store close")
+	}()
+
+	// Login attempts are recorded, except for web sessions.
+	la := LoginAttempt{AccountName: "mjl", LoginAddress: "mjl@mox.example", Protocol: "imap", AuthMech: "plain", Result: AuthBadPassword, log: pkglog}
+	loginAttemptWrite(la)
+	loginAttemptWrite(la)
+	ws := la
+	ws.AuthMech = "websession"
+	loginAttemptWrite(ws)
+
+	old := time.Now().Add(-400 * 24 * time.Hour)
+	AuditAdd(ctxbg, pkglog, AuditEvent{Time: old, Kind: AuditConfig, Actor: "(admin)", Protocol: "webadmin", Action: "DomainAdd", Result: "ok"})
+	AuditAdd(ctxbg, pkglog, AuditEvent{Kind: AuditPassword, Account: "mjl", Actor: "(ctl)", Protocol: "ctl", Action: "setaccountpassword", Result: "ok"})
+
+	l, err := AuditList(ctxbg, AuditFilter{}, 0)
+	tcheck(t, err, "list audit events")
+	tcompare(t, len(l), 4)
+	tcompare(t, l[0].Kind, AuditPassword) // Most recent first.
+
+	l, err = AuditList(ctxbg, AuditFilter{Kind: AuditLogin, Account: "mjl"}, 0)
+	tcheck(t, err, "list audit events")
+	tcompare(t, len(l), 2)
+	tcompare(t, l[0].Result, string(AuthBadPassword))
+	tcompare(t, l[0].Details, "mjl@mox.example")
+
+	l, err = AuditList(ctxbg, AuditFilter{}, 1)
+	tcheck(t, err, "list audit events")
+	tcompare(t, len(l), 1)
+
+	start := time.Now().Add(-time.Hour)
+	l, err = AuditList(ctxbg, AuditFilter{Start: &start}, 0)
+	tcheck(t, err, "list audit events")
+	tcompare(t, len(l), 3)
+
+	var n int
+	err = AuditForEach(ctxbg, AuditFilter{Actor: "(admin)"}, func(e AuditEvent) error {
+		n++
+		return nil
+	})
+	tcheck(t, err, "audit events foreach")
+	tcompare(t, n, 1)
+
+	// Old event is removed.
+	err = AuditCleanup(ctxbg)
+	tcheck(t, err, "audit cleanup")
+	l, err = AuditList(ctxbg, AuditFilter{}, 0)
+	tcheck(t, err, "list audit events")
+	tcompare(t, len(l), 3)
+
+	// Nothing is recorded when disabled.
+	mox.Conf.Static.AuditLog = &config.AuditLog{Disabled: true}
+	AuditAdd(ctxbg, pkglog, AuditEvent{Kind: AuditConfig, Action: "DomainAdd", Result: "ok"})
+	loginAttemptWrite(la)
+	mox.Conf.Static.AuditLog = nil
+	l, err = AuditList(ctxbg, AuditFilter{}, 0)
+	tcheck(t, err, "list audit events")
+	tcompare(t, len(l), 3)
+
+	e := AuditEvent{
+		ID:       1,
+		Time:     time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		Kind:     AuditLogin,
+		Account:  "mjl",
+		Protocol: "imap",
+		Action:   "plain",
+		Result:   "badpassword",
+		Details:  `a"]\b` + "\n",
+	}
+	exp := `<84>1 2025-01-02T03:04:05Z mail.mox.example mox - login [audit@32473 id="1" account="mjl" protocol="imap" action="plain" result="badpassword"] login plain: badpassword, a"]\b `
+	tcompare(t, e.Syslog("mail.mox.example"), exp)
+	e.Account = `x"]\`
+	e.Result = "ok"
+	e.Details = ""
+	exp = `<85>1 2025-01-02T03:04:05Z - mox - login [audit@32473 id="1" account="x\"\]\\" protocol="imap" action="plain" result="ok"] login plain: ok`
+	tcompare(t, e.Syslog(""), exp)
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"net/textproto"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/mox/config"
+)
+
+// Categories of incoming bulk messages, see config.Categories. Categorized
+// messages get the category as keyword, prefixed with "$".
+const (
+	CategoryNewsletter   = "newsletter"
+	CategoryNotification = "notification"
+	CategorySocial       = "social"
+)
+
+// Domains of social networks, for the social category without configured domains.
+var socialDomains = []string{
+	"facebookmail.com",
+	"instagram.com",
+	"linkedin.com",
+	"pinterest.com",
+	"reddit.com",
+	"tiktok.com",
+	"twitter.com",
+	"x.com",
+}
+
+// Localparts of automated senders, lower case and with non-alphanumeric
+// characters removed.
+var notificationLocalparts = []string{
+	"alert",
+	"alerts",
+	"donotreply",
+	"noreply",
+	"notification",
+	"notifications",
+	"notify",
+}
+
+// Categorize returns the category of incoming message m with message headers h,
+// and its configuration. If the message is not in a category, or the category is
+// not configured, an empty category is returned.
+//
+// Verified sender domains configured for a category take precedence. Otherwise
+// the message is a social update if from a social network domain, a
+// notification if it has an Auto-Submitted header, a newsletter if it has
+// mailing list headers or bulk precedence, and a notification if the sender
+// localpart looks automated.
+func Categorize(conf config.Categories, m Message, h textproto.MIMEHeader) (string, *config.Category) {
+	// Domains verified to have sent the message.
+	verified := slices.Clone(m.DKIMDomains)
+	if m.MsgFromValidated && m.MsgFromDomain != "" {
+		verified = append(verified, m.MsgFromDomain)
+	}
+	matchDomain := func(d string) bool {
+		suffix := "." + d
+		return slices.ContainsFunc(verified, func(s string) bool {
+			return s == d || strings.HasSuffix(s, suffix)
+		})
+	}
+
+	categories := []struct {
+		name string
+		conf *config.Category
+	}{
+		{CategorySocial, conf.Social},
+		{CategoryNotification, conf.Notifications},
+		{CategoryNewsletter, conf.Newsletters},
+	}
+	for _, c := range categories {
+		if c.conf == nil {
+			continue
+		}
+		for _, d := range c.conf.DNSDomains {
+			if matchDomain(d.Name()) {
+				return c.name, c.conf
+			}
+		}
+	}
+
+	result := func(name string) (string, *config.Category) {
+		for _, c := range categories {
+			if c.name == name && c.conf != nil {
+				return c.name, c.conf
+			}
+		}
+		return "", nil
+	}
+
+	if conf.Social == nil || len(conf.Social.DNSDomains) == 0 {
+		if slices.ContainsFunc(socialDomains, matchDomain) {
+			return result(CategorySocial)
+		}
+	}
+
+	// ../rfc/3834
+	autoSubmitted, _, _ := strings.Cut(h.Get("Auto-Submitted"), ";")
+	autoSubmitted = strings.ToLower(strings.TrimSpace(autoSubmitted))
+	if autoSubmitted != "" && autoSubmitted != "no" {
+		return result(CategoryNotification)
+	}
+
+	// ../rfc/2919:174 ../rfc/2369:167
+	precedence := strings.ToLower(strings.TrimSpace(h.Get("Precedence")))
+	if h.Get("List-Id") != "" || h.Get("List-Unsubscribe") != "" || precedence == "bulk" || precedence == "list" {
+		return result(CategoryNewsletter)
+	}
+
+	lp := strings.Map(func(c rune) rune {
+		if c >= 'a' && c <= 'z' || c >= '0' && c <= '9' {
+			return c
+		}
+		return -1
+	}, strings.ToLower(string(m.MsgFromLocalpart)))
+	if slices.Contains(notificationLocalparts, lp) {
+		return result(CategoryNotification)
+	}
+
+	return "", nil
+}
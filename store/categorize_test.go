@@ -0,0 +1,61 @@
+package store
+
+import (
+	"net/textproto"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+)
+
+func TestCategorize(t *testing.T) {
+	newsletters := &config.Category{Mailbox: "Newsletters"}
+	notifications := &config.Category{DNSDomains: []dns.Domain{{ASCII: "github.com"}}}
+	social := &config.Category{Mailbox: "Social"}
+	conf := config.Categories{Newsletters: newsletters, Notifications: notifications, Social: social}
+
+	test := func(conf config.Categories, m Message, h textproto.MIMEHeader, expCategory string, expConf *config.Category) {
+		t.Helper()
+		category, cat := Categorize(conf, m, h)
+		tcompare(t, category, expCategory)
+		tcompare(t, cat, expConf)
+	}
+
+	person := Message{MsgFromLocalpart: "mjl", MsgFromDomain: "example.org", MsgFromValidated: true}
+	test(conf, person, textproto.MIMEHeader{}, "", nil)
+
+	list := textproto.MIMEHeader{"List-Id": {"<list.example.org>"}}
+	test(conf, person, list, CategoryNewsletter, newsletters)
+	test(conf, person, textproto.MIMEHeader{"List-Unsubscribe": {"<https://example.org/unsubscribe>"}}, CategoryNewsletter, newsletters)
+	test(conf, person, textproto.MIMEHeader{"Precedence": {" Bulk "}}, CategoryNewsletter, newsletters)
+	test(conf, person, textproto.MIMEHeader{"Precedence": {"junk"}}, "", nil)
+
+	// Auto-Submitted takes precedence over list headers.
+	autoSubmitted := textproto.MIMEHeader{"Auto-Submitted": {"auto-generated; owner-email=x@example.org"}, "List-Id": {"<list.example.org>"}}
+	test(conf, person, autoSubmitted, CategoryNotification, notifications)
+	test(conf, person, textproto.MIMEHeader{"Auto-Submitted": {"no"}}, "", nil)
+
+	noreply := Message{MsgFromLocalpart: "No-Reply", MsgFromDomain: "example.org"}
+	test(conf, noreply, textproto.MIMEHeader{}, CategoryNotification, notifications)
+	test(conf, noreply, list, CategoryNewsletter, newsletters)
+
+	// Social network domains, only if verified.
+	linkedin := Message{MsgFromLocalpart: "messages-noreply", MsgFromDomain: "linkedin.com", MsgFromValidated: true}
+	test(conf, linkedin, list, CategorySocial, social)
+	linkedin.MsgFromValidated = false
+	test(conf, linkedin, list, CategoryNewsletter, newsletters)
+	linkedin.DKIMDomains = []string{"bounce.linkedin.com"}
+	test(conf, linkedin, list, CategorySocial, social)
+
+	// Configured domains replace the default social network domains, and take
+	// precedence over headers.
+	social.DNSDomains = []dns.Domain{{ASCII: "social.example"}}
+	test(conf, linkedin, list, CategoryNewsletter, newsletters)
+	social.DNSDomains = nil
+	github := Message{MsgFromLocalpart: "notifications", MsgFromDomain: "github.com", DKIMDomains: []string{"github.com"}}
+	test(conf, github, list, CategoryNotification, notifications)
+
+	// Unconfigured categories are not used.
+	test(config.Categories{Newsletters: newsletters}, person, autoSubmitted, "", nil)
+	test(config.Categories{Notifications: notifications}, person, list, "", nil)
+}
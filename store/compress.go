@@ -0,0 +1,560 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+)
+
+// Compressed message files start with a magic, followed by the chunks of the
+// message, each compressed independently with DEFLATE, followed by an index with
+// the file offset of each chunk, and a fixed-size trailer. Chunks allow reading
+// parts of a message, e.g. for IMAP FETCH of a MIME part, without decompressing
+// the whole message.
+//
+// Zstandard would compress better and faster, but isn't available in the Go
+// standard library.
+//
+// Whether a message file is compressed is stored in Message.Compressed, file
+// contents are never used to decide. Incoming messages can contain anything,
+// including the magic and a trailer. The magic only helps detect mistakes.
+const compressMagic = "\x00moxz01\n"
+
+const (
+	compressChunkSize   = 64 * 1024
+	compressTrailerSize = 8 + 4 + 4 + 8 // Uncompressed size, chunk size, number of chunks, index offset.
+)
+
+// Limits for compressed files. Files exceeding them are considered corrupt,
+// preventing large allocations for bad files. DEFLATE cannot compress by more
+// than a factor 1032.
+const (
+	compressMaxChunkSize = 1024 * 1024
+	compressMaxChunks    = 1 << 20
+	compressMaxRatio     = 1032
+)
+
+var errCompressCorrupt = errors.New("corrupt compressed message file")
+
+// msgFile is an opened on-disk message file, compressed or not.
+type msgFile interface {
+	io.ReaderAt
+	io.Closer
+}
+
+// compressedFile provides access to the uncompressed data of a compressed
+// message file.
+type compressedFile struct {
+	f         *os.File
+	size      int64   // Uncompressed size.
+	chunkSize int64   // Uncompressed size of each chunk, except the last.
+	offsets   []int64 // File offset of each chunk, and of the index.
+
+	chunk int    // Index of chunk in buf, -1 if none.
+	buf   []byte // Decompressed data of chunk.
+}
+
+// openCompressed reads the index of compressed message file f.
+func openCompressed(f *os.File) (*compressedFile, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if fi.Size() < int64(len(compressMagic)+compressTrailerSize) {
+		return nil, errCompressCorrupt
+	}
+	magic := make([]byte, len(compressMagic))
+	if _, err := f.ReadAt(magic, 0); err != nil {
+		return nil, fmt.Errorf("reading magic: %w", err)
+	} else if string(magic) != compressMagic {
+		return nil, errCompressCorrupt
+	}
+	trailer := make([]byte, compressTrailerSize)
+	if _, err := f.ReadAt(trailer, fi.Size()-compressTrailerSize); err != nil {
+		return nil, fmt.Errorf("reading trailer: %w", err)
+	}
+	size := int64(binary.BigEndian.Uint64(trailer[0:8]))
+	chunkSize := int64(binary.BigEndian.Uint32(trailer[8:12]))
+	nchunks := int64(binary.BigEndian.Uint32(trailer[12:16]))
+	indexOffset := int64(binary.BigEndian.Uint64(trailer[16:24]))
+	// Each chunk takes at least one byte in the file.
+	fileSize := fi.Size()
+	if size < 0 || chunkSize == 0 || chunkSize > compressMaxChunkSize || nchunks > compressMaxChunks || nchunks > fileSize || size > compressMaxRatio*fileSize || nchunks != (size+chunkSize-1)/chunkSize || indexOffset < int64(len(compressMagic)) || indexOffset+8*nchunks != fileSize-compressTrailerSize {
+		return nil, errCompressCorrupt
+	}
+	index := make([]byte, 8*nchunks)
+	if _, err := f.ReadAt(index, indexOffset); err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+	offsets := make([]int64, nchunks+1)
+	for i := range nchunks {
+		offsets[i] = int64(binary.BigEndian.Uint64(index[8*i:]))
+		if offsets[i] < int64(len(compressMagic)) || i > 0 && offsets[i] < offsets[i-1] || offsets[i] > indexOffset {
+			return nil, errCompressCorrupt
+		}
+	}
+	offsets[nchunks] = indexOffset
+	return &compressedFile{f: f, size: size, chunkSize: chunkSize, offsets: offsets, chunk: -1}, nil
+}
+
+// load decompresses chunk i into buf, if not already present.
+func (cf *compressedFile) load(i int) error {
+	if cf.chunk == i {
+		return nil
+	}
+	cf.chunk = -1
+	o := cf.offsets[i]
+	r := flate.NewReader(io.NewSectionReader(cf.f, o, cf.offsets[i+1]-o))
+	defer r.Close()
+	n := min(cf.chunkSize, cf.size-int64(i)*cf.chunkSize)
+	if int64(cap(cf.buf)) < n {
+		cf.buf = make([]byte, n)
+	}
+	cf.buf = cf.buf[:n]
+	if _, err := io.ReadFull(r, cf.buf); err != nil {
+		return fmt.Errorf("%w: decompressing chunk %d: %v", errCompressCorrupt, i, err)
+	}
+	cf.chunk = i
+	return nil
+}
+
+// ReadAt reads uncompressed data, with the semantics of os.File.ReadAt.
+func (cf *compressedFile) ReadAt(buf []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+	var n int
+	for n < len(buf) {
+		if off >= cf.size {
+			return n, io.EOF
+		}
+		i := int(off / cf.chunkSize)
+		if err := cf.load(i); err != nil {
+			return n, err
+		}
+		nn := copy(buf[n:], cf.buf[off-int64(i)*cf.chunkSize:])
+		n += nn
+		off += int64(nn)
+	}
+	return n, nil
+}
+
+func (cf *compressedFile) Close() error {
+	return cf.f.Close()
+}
+
+// openMsgFile returns f, or a reader of its uncompressed data if compressed is
+// set. The size of the (uncompressed) data is returned.
+func openMsgFile(f *os.File, compressed bool) (msgFile, int64, error) {
+	if compressed {
+		cf, err := openCompressed(f)
+		if err != nil {
+			return nil, 0, err
+		}
+		return cf, cf.size, nil
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, 0, err
+	}
+	return f, fi.Size(), nil
+}
+
+// openMessageFile is like openMsgFile, but for the on-disk file of a stored
+// message with size bytes of data, not including MsgPrefix, or -1 if unknown.
+//
+// CompressMessages can replace the file of an uncompressed message with a
+// compressed copy after the caller read the message from the database, e.g. in a
+// transaction that is still open. A compressed copy is only stored if it is smaller
+// than the original. A file that starts with the magic and is smaller than the
+// size from the database must be such a copy and is read as compressed. Message
+// contents alone can never cause a file to be read as compressed.
+func openMessageFile(f *os.File, compressed bool, size int64) (msgFile, int64, error) {
+	if !compressed && size >= 0 {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, 0, err
+		}
+		if fi.Size() < size {
+			magic := make([]byte, len(compressMagic))
+			if _, err := f.ReadAt(magic, 0); err == nil && string(magic) == compressMagic {
+				compressed = true
+			}
+		}
+	}
+	return openMsgFile(f, compressed)
+}
+
+// MsgFileSize returns the size of the message data in the on-disk message file at
+// path, which is uncompressed if compressed is set, see Message.Compressed, and the
+// size of the file itself.
+func MsgFileSize(path string, compressed bool) (size, storedSize int64, rerr error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+	_, size, err = openMsgFile(f, compressed)
+	if err != nil {
+		return 0, 0, err
+	}
+	return size, fi.Size(), nil
+}
+
+// compressMsgFile writes the data read from src, of size bytes, to dst in the
+// compressed message file format, with compression level 1 to 9.
+func compressMsgFile(dst io.Writer, src io.ReaderAt, size int64, level int) error {
+	if _, err := dst.Write([]byte(compressMagic)); err != nil {
+		return err
+	}
+	offset := int64(len(compressMagic))
+
+	var index []byte
+	var cbuf bytes.Buffer
+	w, err := flate.NewWriter(&cbuf, level)
+	if err != nil {
+		return err
+	}
+	buf := make([]byte, compressChunkSize)
+	var nchunks int
+	for o := int64(0); o < size; o += compressChunkSize {
+		n := min(compressChunkSize, size-o)
+		if _, err := src.ReadAt(buf[:n], o); err != nil && !(err == io.EOF && o+n == size) {
+			return fmt.Errorf("reading message: %w", err)
+		}
+		cbuf.Reset()
+		w.Reset(&cbuf)
+		if _, err := w.Write(buf[:n]); err != nil {
+			return err
+		}
+		if err := w.Close(); err != nil {
+			return err
+		}
+		index = binary.BigEndian.AppendUint64(index, uint64(offset))
+		if _, err := dst.Write(cbuf.Bytes()); err != nil {
+			return err
+		}
+		offset += int64(cbuf.Len())
+		nchunks++
+	}
+
+	trailer := binary.BigEndian.AppendUint64(nil, uint64(size))
+	trailer = binary.BigEndian.AppendUint32(trailer, compressChunkSize)
+	trailer = binary.BigEndian.AppendUint32(trailer, uint32(nchunks))
+	trailer = binary.BigEndian.AppendUint64(trailer, uint64(offset))
+	_, err = dst.Write(append(index, trailer...))
+	return err
+}
+
+// compressConfig returns the configured compression level and minimum message
+// size, and whether compression is enabled.
+func compressConfig() (level int, minSize int64, ok bool) {
+	mc := mox.Conf.Static.MessageCompression
+	if mc == nil {
+		return 0, 0, false
+	}
+	level = mc.Level
+	if level == 0 {
+		level = 6
+	}
+	minSize = mc.MinSize
+	if minSize == 0 {
+		minSize = 1024
+	}
+	return level, minSize, true
+}
+
+// compressTemp returns a compressed copy of uncompressed msgFile in a new
+// temporary file if compression is configured, msgFile is at least the
+// configured minimum size, and compression results in a smaller file. The
+// returned storedSize is the size of the file to store: the compressed copy if
+// returned, msgFile otherwise. StoredSize is zero if compression is not
+// configured. The caller must remove a returned temporary file.
+func compressTemp(log mlog.Log, msgFile *os.File) (tmpFile *os.File, storedSize int64, rerr error) {
+	fi, err := msgFile.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("stat message file: %v", err)
+	}
+	level, minSize, ok := compressConfig()
+	if !ok {
+		return nil, 0, nil
+	} else if fi.Size() < minSize {
+		return nil, fi.Size(), nil
+	}
+
+	f, err := CreateMessageTemp(log, "compress")
+	if err != nil {
+		return nil, 0, fmt.Errorf("creating temporary file: %v", err)
+	}
+	defer func() {
+		if f != nil {
+			CloseRemoveTempFile(log, f, "compressed message")
+		}
+	}()
+	bw := bufio.NewWriter(f)
+	if err := compressMsgFile(bw, msgFile, fi.Size(), level); err != nil {
+		return nil, 0, fmt.Errorf("compressing message: %v", err)
+	}
+	if err := bw.Flush(); err != nil {
+		return nil, 0, fmt.Errorf("writing compressed message: %v", err)
+	}
+	cfi, err := f.Stat()
+	if err != nil {
+		return nil, 0, fmt.Errorf("stat compressed message file: %v", err)
+	}
+	if cfi.Size() >= fi.Size() {
+		return nil, fi.Size(), nil
+	}
+	tmpFile = f
+	f = nil
+	return tmpFile, cfi.Size(), nil
+}
+
+// MessageStorage holds the sizes of messages in an account.
+type MessageStorage struct {
+	Messages   int   // Number of messages, excluding expunged messages.
+	Compressed int   // Number of messages stored compressed.
+	Size       int64 // Total uncompressed size of messages.
+	StoredSize int64 // Total size of messages as stored, including the message prefixes in the database.
+}
+
+// MessageStorage returns the uncompressed and stored sizes of the messages in the
+// account. Messages added before compression was enabled and not yet compressed
+// are counted as uncompressed.
+func (a *Account) MessageStorage(ctx context.Context) (ms MessageStorage, rerr error) {
+	rerr = a.DB.Read(ctx, func(tx *bstore.Tx) error {
+		q := bstore.QueryTx[Message](tx)
+		q.FilterEqual("Expunged", false)
+		return q.ForEach(func(m Message) error {
+			ms.Messages++
+			ms.Size += m.Size
+			if m.Compressed {
+				ms.Compressed++
+				ms.StoredSize += int64(len(m.MsgPrefix)) + m.StoredSize
+			} else {
+				ms.StoredSize += m.Size
+			}
+			return nil
+		})
+	})
+	return
+}
+
+// CompressMessages compresses the on-disk files of messages that were added
+// before compression was configured with MessageCompression, and sets their
+// StoredSize. Message files are replaced with a compressed copy with a rename, for
+// hard-linked message files only the link of the message is replaced.
+//
+// The number of compressed messages and bytes saved on disk are returned.
+func (a *Account) CompressMessages(ctx context.Context, log mlog.Log) (n int, saved int64, rerr error) {
+	if _, _, ok := compressConfig(); !ok {
+		return 0, 0, nil
+	}
+
+	var lastID int64
+	for {
+		var l []Message
+		err := a.DB.Read(ctx, func(tx *bstore.Tx) error {
+			q := bstore.QueryTx[Message](tx)
+			q.FilterEqual("Expunged", false)
+			q.FilterEqual("StoredSize", int64(0))
+			q.FilterGreater("ID", lastID)
+			q.SortAsc("ID")
+			q.Limit(100)
+			var err error
+			l, err = q.List()
+			return err
+		})
+		if err != nil {
+			return n, saved, fmt.Errorf("listing messages to compress: %v", err)
+		}
+		if len(l) == 0 {
+			return n, saved, nil
+		}
+
+		msgDirs := map[string]struct{}{}
+		for _, m := range l {
+			lastID = m.ID
+			if err := ctx.Err(); err != nil {
+				return n, saved, err
+			}
+
+			// Files of messages that aren't compressed yet are only replaced below, by us, so
+			// we can read without holding the lock. Readers that still have the message
+			// with Compressed false after the replacement detect the compressed copy by its
+			// smaller size, see openMessageFile.
+			p := a.MessagePath(m.ID)
+			f, err := os.Open(p)
+			if err != nil {
+				// Message may have been removed in the mean time.
+				log.Debugx("opening message file for compressing, skipping", err, slog.Int64("msgid", m.ID))
+				continue
+			}
+			tmpf, storedSize, err := compressTemp(log, f)
+			xerr := f.Close()
+			log.Check(xerr, "closing message file")
+			if err != nil {
+				return n, saved, fmt.Errorf("compressing message %d: %v", m.ID, err)
+			}
+
+			a.WithWLock(func() {
+				// The message file is only replaced after the transaction committed, so a
+				// failed update or commit leaves the uncompressed file in place.
+				var replacePath string
+				var xm Message
+				err = a.DB.Write(ctx, func(tx *bstore.Tx) error {
+					xm = Message{ID: m.ID}
+					if err := tx.Get(&xm); err != nil {
+						if errors.Is(err, bstore.ErrAbsent) {
+							return nil
+						}
+						return fmt.Errorf("get message: %v", err)
+					}
+					if xm.Expunged || xm.StoredSize != 0 {
+						return nil
+					}
+					if tmpf != nil {
+						if err := tmpf.Sync(); err != nil {
+							return fmt.Errorf("sync compressed message file: %v", err)
+						}
+						// With deduplication, we replace the message file with a link to the file in the
+						// dedup directory. Renaming tmpf itself is fine if it was just added there.
+						replacePath = tmpf.Name()
+						if src := dedupSource(log, tmpf); src != replacePath {
+							lp := replacePath + ".dedup"
+							if err := os.Link(src, lp); err != nil {
+								log.Debugx("linking deduplicated message file, using compressed file", err, slog.String("path", src))
+							} else {
+								// Removed below, unless renamed over the message file.
+								replacePath = lp
+							}
+						}
+						xm.Compressed = true
+					}
+					xm.StoredSize = storedSize
+					return tx.Update(&xm)
+				})
+				if err != nil || !xm.Compressed {
+					if replacePath != "" && replacePath != tmpf.Name() {
+						xerr := os.Remove(replacePath)
+						log.Check(xerr, "removing link to deduplicated message file")
+					}
+					return
+				}
+				if err = os.Rename(replacePath, p); err != nil {
+					err = fmt.Errorf("replacing message file with compressed file: %v", err)
+					// Restore the message to uncompressed, matching the file.
+					xerr := a.DB.Write(context.Background(), func(tx *bstore.Tx) error {
+						xm.Compressed = false
+						xm.StoredSize = 0
+						return tx.Update(&xm)
+					})
+					log.Check(xerr, "restoring message after failing to replace message file")
+					if replacePath != tmpf.Name() {
+						xerr := os.Remove(replacePath)
+						log.Check(xerr, "removing link to deduplicated message file")
+					}
+					return
+				}
+				msgDirs[filepath.Dir(p)] = struct{}{}
+				n++
+				saved += xm.Size - int64(len(xm.MsgPrefix)) - storedSize
+			})
+			if tmpf != nil {
+				name := tmpf.Name()
+				xerr := tmpf.Close()
+				log.Check(xerr, "closing compressed message file")
+				if _, xerr := os.Stat(name); xerr == nil {
+					xerr = os.Remove(name)
+					log.Check(xerr, "removing compressed message file")
+				}
+			}
+			if err != nil {
+				return n, saved, err
+			}
+		}
+		for dir := range msgDirs {
+			if err := moxio.SyncDir(log, dir); err != nil {
+				return n, saved, fmt.Errorf("sync message directory: %v", err)
+			}
+		}
+	}
+}
+
+// CompressMessagesStart starts a goroutine that compresses messages of all
+// accounts that were added before compression was configured, see
+// Account.CompressMessages. Only if MessageCompression is configured.
+func CompressMessagesStart() {
+	if _, _, ok := compressConfig(); !ok {
+		return
+	}
+
+	go func() {
+		log := mlog.New("store", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Store)
+			}
+		}()
+
+		// Don't slow down startup.
+		select {
+		case <-mox.Shutdown.Done():
+			return
+		case <-time.After(time.Minute):
+		}
+
+		for _, name := range mox.Conf.Accounts() {
+			compressAccount(log.WithCid(mox.Cid()), name)
+			if mox.Shutdown.Err() != nil {
+				return
+			}
+		}
+	}()
+}
+
+func compressAccount(log mlog.Log, name string) {
+	acc, err := OpenAccount(log, name, false)
+	if err != nil {
+		log.Errorx("open account for compressing messages", err, slog.String("account", name))
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	start := time.Now()
+	n, saved, err := acc.CompressMessages(mox.Shutdown, log)
+	if err != nil {
+		log.Errorx("compressing messages", err, slog.String("account", name), slog.Int("compressed", n))
+	} else if n > 0 {
+		log.Info("compressed messages", slog.String("account", name), slog.Int("compressed", n), slog.Int64("saved", saved), slog.Duration("duration", time.Since(start)))
+	}
+}
@@ -0,0 +1,165 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestCompress(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	// Message spanning multiple chunks.
+	var msgbuf bytes.Buffer
+	msgbuf.WriteString("Subject: test\r\n\r\n")
+	for i := 0; msgbuf.Len() < 3*compressChunkSize; i++ {
+		fmt.Fprintf(&msgbuf, "line %d of a compressible message\r\n", i)
+	}
+	msg := msgbuf.Bytes()
+	prefix := []byte("Received: from localhost\r\n")
+
+	// Compressed files are read transparently, also at chunk boundaries.
+	f, err := CreateMessageTemp(log, "compress-test")
+	tcheck(t, err, "create temp file")
+	defer CloseRemoveTempFile(log, f, "compress test")
+	err = compressMsgFile(f, bytes.NewReader(msg), int64(len(msg)), 6)
+	tcheck(t, err, "compress message")
+	mr := fileMsgReader(prefix, f, true, -1)
+	tcompare(t, mr.Size(), int64(len(prefix)+len(msg)))
+	buf, err := io.ReadAll(mr)
+	tcheck(t, err, "read compressed message")
+	tcompare(t, bytes.Equal(buf, append(append([]byte{}, prefix...), msg...)), true)
+	for _, off := range []int64{0, compressChunkSize - 10, 2*compressChunkSize + 1} {
+		buf := make([]byte, 20)
+		n, err := mr.ReadAt(buf, int64(len(prefix))+off)
+		tcheck(t, err, "readat")
+		tcompare(t, string(buf[:n]), string(msg[off:off+20]))
+	}
+	size, storedSize, err := MsgFileSize(f.Name(), true)
+	tcheck(t, err, "msg file size")
+	tcompare(t, size, int64(len(msg)))
+	if storedSize >= size {
+		t.Fatalf("stored size %d not smaller than size %d", storedSize, size)
+	}
+
+	deliver := func() Message {
+		t.Helper()
+		f, err := CreateMessageTemp(log, "compress-test")
+		tcheck(t, err, "create temp file")
+		defer CloseRemoveTempFile(log, f, "compress test")
+		_, err = f.Write(msg)
+		tcheck(t, err, "write message")
+		m := Message{Received: time.Now(), Size: int64(len(prefix) + len(msg)), MsgPrefix: prefix}
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(log, "Inbox", &m, f)
+		})
+		tcheck(t, err, "deliver message")
+		return m
+	}
+
+	checkMessage := func(id int64) {
+		t.Helper()
+		m, err := bstore.QueryDB[Message](ctxbg, acc.DB).FilterID(id).Get()
+		tcheck(t, err, "get message")
+		buf, err := io.ReadAll(acc.MessageReader(m))
+		tcheck(t, err, "read message")
+		tcompare(t, bytes.Equal(buf, append(append([]byte{}, prefix...), msg...)), true)
+	}
+
+	// Without compression, messages are stored as is.
+	m0 := deliver()
+	tcompare(t, m0.StoredSize, int64(0))
+
+	mox.Conf.Static.MessageCompression = &config.MessageCompression{}
+	defer func() {
+		mox.Conf.Static.MessageCompression = nil
+	}()
+
+	m1 := deliver()
+	if m1.StoredSize <= 0 || m1.StoredSize >= int64(len(msg)) {
+		t.Fatalf("stored size %d, expected compressed size", m1.StoredSize)
+	}
+	tcompare(t, m1.Compressed, true)
+	checkMessage(m1.ID)
+
+	ms, err := acc.MessageStorage(ctxbg)
+	tcheck(t, err, "message storage")
+	tcompare(t, ms.Messages, 2)
+	tcompare(t, ms.Compressed, 1)
+	tcompare(t, ms.Size, 2*m0.Size)
+	tcompare(t, ms.StoredSize, m0.Size+int64(len(prefix))+m1.StoredSize)
+
+	// Messages added before compression was enabled are compressed in the background.
+	n, saved, err := acc.CompressMessages(ctxbg, log)
+	tcheck(t, err, "compress messages")
+	tcompare(t, n, 1)
+	tcompare(t, saved, int64(len(msg))-m1.StoredSize)
+	checkMessage(m0.ID)
+	// Readers with the message from before compression still read it correctly.
+	buf, err = io.ReadAll(acc.MessageReader(m0))
+	tcheck(t, err, "read message compressed after reading from database")
+	tcompare(t, bytes.Equal(buf, append(append([]byte{}, prefix...), msg...)), true)
+	n, _, err = acc.CompressMessages(ctxbg, log)
+	tcheck(t, err, "compress messages again")
+	tcompare(t, n, 0)
+
+	ms, err = acc.MessageStorage(ctxbg)
+	tcheck(t, err, "message storage")
+	tcompare(t, ms.Compressed, 2)
+
+	// A message can look like a compressed file, but is only treated as one if stored
+	// compressed. Message with the magic and a trailer with a huge chunk size.
+	var crafted bytes.Buffer
+	crafted.WriteString(compressMagic)
+	crafted.WriteString("Subject: not compressed\r\n\r\nbody\r\n")
+	offset := crafted.Len()
+	crafted.Write(make([]byte, 8)) // Index with one chunk.
+	crafted.Write([]byte{0, 0, 0, 0, 0xff, 0xff, 0xff, 0xff})
+	crafted.Write([]byte{0xff, 0xff, 0xff, 0xff, 0, 0, 0, 1})
+	crafted.Write([]byte{0, 0, 0, 0, 0, 0, 0, byte(offset)})
+	mox.Conf.Static.MessageCompression = nil
+	msg = crafted.Bytes()
+	m2 := deliver()
+	tcompare(t, m2.Compressed, false)
+	checkMessage(m2.ID)
+
+	// Reading the crafted message as compressed file fails without large allocation.
+	cf, err := os.Open(acc.MessagePath(m2.ID))
+	tcheck(t, err, "open message file")
+	defer cf.Close()
+	_, err = openCompressed(cf)
+	if !errors.Is(err, errCompressCorrupt) {
+		t.Fatalf("got err %v, expected errCompressCorrupt", err)
+	}
+
+	err = acc.CheckConsistency()
+	tcheck(t, err, "check consistency")
+}
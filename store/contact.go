@@ -0,0 +1,123 @@
+package store
+
+import (
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/ical"
+	"github.com/mjl-/mox/smtp"
+)
+
+// ContactCollectName is the name of the address book to which recipient
+// addresses of sent messages are added.
+const ContactCollectName = "collected"
+
+// Number of messages sent to an address before it is added to the address book
+// with collected addresses.
+const contactCollectThreshold = 2
+
+// ETag returns the entity tag for the data of a calendar object or contact,
+// without quotes.
+func ETag(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:16])
+}
+
+// ContactParse parses a vCard, returning its UID, full name and lower case email
+// addresses.
+func ContactParse(data string) (uid, fullName string, emails []string, rerr error) {
+	card, err := ical.Parse(strings.NewReader(data))
+	if err != nil {
+		return "", "", nil, err
+	}
+	if card.Name != "VCARD" {
+		return "", "", nil, fmt.Errorf("not a vcard")
+	}
+	uid = card.Text("UID")
+	if uid == "" {
+		return "", "", nil, fmt.Errorf("vcard without uid")
+	}
+	for _, p := range card.Properties {
+		if p.Name == "EMAIL" {
+			// Value can be a mailto URI in vCard 4.
+			email := strings.ToLower(strings.TrimPrefix(strings.TrimSpace(p.Text()), "mailto:"))
+			if email != "" && !slices.Contains(emails, email) {
+				emails = append(emails, email)
+			}
+		}
+	}
+	return uid, card.Text("FN"), emails, nil
+}
+
+// contactCollect adds address to the address book with collected addresses, if
+// enough messages have been sent to it and it isn't in an address book yet. Name
+// is the display name from the message, possibly empty. Must be called after
+// adding the Recipient.
+func contactCollect(tx *bstore.Tx, name string, address smtp.Address) error {
+	n, err := bstore.QueryTx[Recipient](tx).FilterNonzero(Recipient{Localpart: address.Localpart.String(), Domain: address.Domain.Name()}).Count()
+	if err != nil {
+		return fmt.Errorf("counting messages sent to recipient: %w", err)
+	} else if n < contactCollectThreshold {
+		return nil
+	}
+
+	email := strings.ToLower(address.String())
+	exists, err := bstore.QueryTx[Contact](tx).FilterFn(func(c Contact) bool { return slices.Contains(c.Emails, email) }).Exists()
+	if err != nil {
+		return fmt.Errorf("looking up contact: %w", err)
+	} else if exists {
+		return nil
+	}
+
+	ab, err := bstore.QueryTx[AddressBook](tx).FilterNonzero(AddressBook{Name: ContactCollectName}).Get()
+	if err == bstore.ErrAbsent {
+		ab = AddressBook{Name: ContactCollectName, DisplayName: "Collected addresses"}
+		err = tx.Insert(&ab)
+	}
+	if err != nil {
+		return fmt.Errorf("get address book for collected addresses: %w", err)
+	}
+
+	buf := make([]byte, 16)
+	if _, err := cryptorand.Read(buf); err != nil {
+		return fmt.Errorf("generating uid: %w", err)
+	}
+	uid := hex.EncodeToString(buf)
+	fullName := name
+	if fullName == "" {
+		fullName = address.String()
+	}
+	card := ical.Component{
+		Name: "VCARD",
+		Properties: []ical.Property{
+			{Name: "VERSION", Value: "3.0"},
+			{Name: "UID", Value: uid},
+			{Name: "FN", Value: ical.Escape(fullName)},
+			{Name: "EMAIL", Params: map[string][]string{"TYPE": {"INTERNET"}}, Value: address.String()},
+		},
+	}
+	data := string(card.Marshal())
+	c := Contact{
+		AddressBookID: ab.ID,
+		Name:          uid + ".vcf",
+		UID:           uid,
+		ETag:          ETag(data),
+		Data:          data,
+		FullName:      fullName,
+		Emails:        []string{email},
+	}
+	if err := tx.Insert(&c); err != nil {
+		return fmt.Errorf("adding contact: %w", err)
+	}
+	ab.ModSeq++
+	if err := tx.Update(&ab); err != nil {
+		return fmt.Errorf("updating address book: %w", err)
+	}
+	return nil
+}
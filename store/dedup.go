@@ -0,0 +1,175 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"time"
+
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+)
+
+// With MessageDeduplication, message files are stored once in the "dedup"
+// directory of the data directory, named after the SHA-256 hash of their contents,
+// and hard linked into accounts. The link count of a file is its reference count:
+// A file in the dedup directory with a single link is no longer used by any
+// account, and is removed by the periodic cleanup.
+//
+// Message files are never modified after they are added to an account, so
+// sharing their inode is safe. Deliveries to multiple local recipients already
+// link the same temporary file into each account. Deduplication also shares files
+// for messages that are delivered or imported separately.
+
+// Interval between cleanups of unreferenced files in the dedup directory.
+const dedupCleanupInterval = 24 * time.Hour
+
+// dedupConfig returns the configured minimum size for deduplication, and whether
+// deduplication is enabled.
+func dedupConfig() (minSize int64, ok bool) {
+	md := mox.Conf.Static.MessageDeduplication
+	if md == nil {
+		return 0, false
+	}
+	minSize = md.MinSize
+	if minSize == 0 {
+		minSize = 4096
+	}
+	return minSize, true
+}
+
+// dedupSource returns the path of the file to link into an account for message
+// file f. If deduplication is configured and f is large enough, this is the path
+// of the file with the same contents in the dedup directory, with f added to the
+// dedup directory if not yet present. Otherwise f's name is returned.
+func dedupSource(log mlog.Log, f *os.File) string {
+	minSize, ok := dedupConfig()
+	if !ok {
+		return f.Name()
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		log.Debugx("stat message file for deduplication", err)
+		return f.Name()
+	}
+	if _, ok := linkCount(fi); !ok || fi.Size() < minSize {
+		return f.Name()
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, fi.Size())); err != nil {
+		log.Debugx("hashing message file for deduplication", err)
+		return f.Name()
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	p := mox.DataDirPath(filepath.Join("dedup", sum[:2], sum))
+
+	if pfi, err := os.Stat(p); err == nil && pfi.Size() == fi.Size() {
+		return p
+	} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Debugx("stat deduplicated message file", err, slog.String("path", p))
+		return f.Name()
+	}
+
+	os.MkdirAll(filepath.Dir(p), 0770)
+	if err := os.Link(f.Name(), p); err != nil {
+		// If added concurrently, unlikely, we'll store our own copy.
+		log.Debugx("adding message file to dedup directory", err, slog.String("path", p))
+		return f.Name()
+	}
+	return p
+}
+
+// dedupLink links the message file f to dst, through the dedup directory if
+// deduplication is configured, see dedupSource. The file is copied if it cannot
+// be linked, e.g. when the file system doesn't support hard links.
+func dedupLink(log mlog.Log, dst string, f *os.File) error {
+	if src := dedupSource(log, f); src != f.Name() {
+		// The file in the dedup directory can be removed by a concurrent cleanup, we
+		// link f in that case.
+		err := os.Link(src, dst)
+		if err == nil {
+			return nil
+		}
+		log.Debugx("linking deduplicated message file, linking message file instead", err, slog.String("path", src))
+	}
+	return moxio.LinkOrCopy(log, dst, f.Name(), &moxio.AtReader{R: f}, true)
+}
+
+// DedupCleanup removes files from the dedup directory that are no longer
+// referenced by accounts, i.e. have a single hard link. The number of removed
+// files and their total size are returned.
+func DedupCleanup(log mlog.Log) (n int, size int64, rerr error) {
+	dir := mox.DataDirPath("dedup")
+	rerr = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == dir {
+			return fs.SkipAll
+		} else if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		fi, err := d.Info()
+		if err != nil {
+			// Possibly removed in the mean time.
+			log.Debugx("stat deduplicated message file", err, slog.String("path", p))
+			return nil
+		}
+		if nlink, ok := linkCount(fi); !ok || nlink > 1 {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		n++
+		size += fi.Size()
+		return nil
+	})
+	return
+}
+
+// DedupCleanupStart starts a goroutine that periodically removes unreferenced
+// files from the dedup directory, see DedupCleanup. Also if MessageDeduplication
+// is not configured, so files from when it was configured are cleaned up.
+func DedupCleanupStart() {
+	go func() {
+		log := mlog.New("store", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Store)
+			}
+		}()
+
+		timer := time.NewTimer(time.Minute)
+		defer timer.Stop()
+		for {
+			select {
+			case <-mox.Shutdown.Done():
+				return
+			case <-timer.C:
+			}
+
+			n, size, err := DedupCleanup(log)
+			if err != nil {
+				log.Errorx("removing unreferenced deduplicated message files", err)
+			} else if n > 0 {
+				log.Info("removed unreferenced deduplicated message files", slog.Int("count", n), slog.Int64("size", size))
+			}
+			timer.Reset(dedupCleanupInterval)
+		}
+	}()
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestDedup(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	mox.Conf.Static.MessageDeduplication = &config.MessageDeduplication{MinSize: 100}
+	defer func() {
+		mox.Conf.Static.MessageDeduplication = nil
+	}()
+
+	var msgbuf bytes.Buffer
+	msgbuf.WriteString("Subject: announcement\r\n\r\n")
+	for i := range 100 {
+		fmt.Fprintf(&msgbuf, "line %d of a message to everyone\r\n", i)
+	}
+	msg := msgbuf.Bytes()
+
+	// Deliver the same message separately, e.g. imported twice, each from its own
+	// temporary file.
+	deliver := func(prefix string) Message {
+		t.Helper()
+		f, err := CreateMessageTemp(log, "dedup-test")
+		tcheck(t, err, "create temp file")
+		defer CloseRemoveTempFile(log, f, "dedup test")
+		_, err = f.Write(msg)
+		tcheck(t, err, "write message")
+		m := Message{Received: time.Now(), Size: int64(len(prefix) + len(msg)), MsgPrefix: []byte(prefix)}
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(log, "Inbox", &m, f)
+		})
+		tcheck(t, err, "deliver message")
+		return m
+	}
+	m0 := deliver("Received: from one\r\n")
+	m1 := deliver("Received: from two\r\n")
+
+	fi0, err := os.Stat(acc.MessagePath(m0.ID))
+	tcheck(t, err, "stat message file")
+	fi1, err := os.Stat(acc.MessagePath(m1.ID))
+	tcheck(t, err, "stat message file")
+	tcompare(t, os.SameFile(fi0, fi1), true)
+	nlink, _ := linkCount(fi0)
+	tcompare(t, nlink, int64(3)) // Two messages and the dedup directory.
+
+	buf, err := io.ReadAll(acc.MessageReader(m1))
+	tcheck(t, err, "read message")
+	tcompare(t, string(buf), "Received: from two\r\n"+string(msg))
+
+	err = acc.CheckConsistency()
+	tcheck(t, err, "check consistency")
+
+	// Still referenced.
+	n, _, err := DedupCleanup(log)
+	tcheck(t, err, "dedup cleanup")
+	tcompare(t, n, 0)
+
+	// Unreferenced after the message files are gone.
+	for _, m := range []Message{m0, m1} {
+		err := os.Remove(acc.MessagePath(m.ID))
+		tcheck(t, err, "remove message file")
+	}
+	n, size, err := DedupCleanup(log)
+	tcheck(t, err, "dedup cleanup")
+	tcompare(t, n, 1)
+	tcompare(t, size, int64(len(msg)))
+
+	// Restore message files for consistency check at close.
+	for _, m := range []Message{m0, m1} {
+		err := os.WriteFile(acc.MessagePath(m.ID), msg, 0660)
+		tcheck(t, err, "restore message file")
+	}
+}
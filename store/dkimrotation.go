@@ -0,0 +1,47 @@
+package store
+
+import (
+	"time"
+)
+
+// DKIMRotation is the state of automatic DKIM key rotation for a domain. A
+// rotation adds and publishes new selectors, switches signing to them after a
+// delay, and removes the old selectors after another delay.
+type DKIMRotation struct {
+	ID     int64
+	Domain string `bstore:"nonzero,unique"` // Unicode name.
+
+	// When the previous rotation was completed, or rotation was enabled. The next
+	// rotation starts an interval after.
+	Rotated time.Time
+
+	// When new selectors were added and published, zero if no rotation is in
+	// progress.
+	Added time.Time
+
+	// When signing switched from the old to the new selectors, zero if not yet.
+	Switched time.Time
+
+	Old []string // Selectors being replaced.
+	New []string // Selectors added, in order of Old.
+
+	// Old selectors removed from the configuration whose DNS records must still be
+	// removed by the administrator, for domains without DNS provisioning. Cleared
+	// when the administrator marks the records as removed.
+	RemoveRecords []string
+}
+
+// DKIMRotationEvent is an entry in the history of DKIM key rotations for a
+// domain.
+type DKIMRotationEvent struct {
+	ID     int64
+	Domain string    `bstore:"nonzero,index Domain+Time"` // Unicode name.
+	Time   time.Time `bstore:"nonzero"`
+
+	// "started" when rotation was enabled, "added" for new selectors, "switched" for
+	// signing with new selectors, "removed" for removed old selectors, "recordsremoved"
+	// when the administrator removed DNS records.
+	Kind      string
+	Selectors []string
+	Text      string // Details, e.g. about DNS records to add or remove.
+}
@@ -122,21 +122,50 @@ func (a *MboxArchiver) Close() error {
 	return nil
 }
 
-// ExportMessages writes messages to archiver. Either in maildir format, or
-// otherwise in mbox. If mailboxOpt is non-empty, all messages from that mailbox
-// are exported. If messageIDsOpt is non-empty, only those message IDs are exported.
-// If both are empty, all mailboxes and all messages are exported. mailboxOpt
-// and messageIDsOpt cannot both be non-empty.
+// ExportFormat is the format in which messages are exported.
+type ExportFormat string
+
+const (
+	// An mbox file per mailbox, in "mboxrd" format.
+	ExportMbox ExportFormat = "mbox"
+
+	// A maildir directory per mailbox, nested like the mailbox hierarchy.
+	ExportMaildir ExportFormat = "maildir"
+
+	// Maildir++ layout as used by Dovecot: Inbox is the maildir at the root, other
+	// mailboxes are maildirs in dot-separated subdirectories starting with a dot.
+	// Includes a dovecot-uidlist file per mailbox with the UIDs of messages, and a
+	// subscriptions file, so the export can be used directly as a Dovecot mail
+	// store, without IMAP clients having to resynchronize. Mailbox names are kept in
+	// UTF-8, and dots in mailbox names are escaped as "\2e", matching Dovecot with
+	// the "UTF-8" mail_location option and the listescape plugin.
+	ExportMaildirPP ExportFormat = "maildir++"
+)
+
+// ExportMessages writes messages to archiver, in the given format. If mailboxOpt
+// is non-empty, all messages from that mailbox are exported. If messageIDsOpt is
+// non-empty, only those message IDs are exported. If both are empty, all
+// mailboxes and all messages are exported. mailboxOpt and messageIDsOpt cannot
+// both be non-empty. Message IDs cannot be exported in maildir++ format.
 //
 // Some errors are not fatal and result in skipped messages. In that happens, a
 // file "errors.txt" is added to the archive describing the errors. The goal is to
 // let users export (hopefully) most messages even in the face of errors.
-func ExportMessages(ctx context.Context, log mlog.Log, db *bstore.DB, accountDir string, archiver Archiver, maildir bool, mailboxOpt string, messageIDsOpt []int64, recursive bool) error {
+func ExportMessages(ctx context.Context, log mlog.Log, db *bstore.DB, accountDir string, archiver Archiver, format ExportFormat, mailboxOpt string, messageIDsOpt []int64, recursive bool) error {
 	// todo optimize: should prepare next file to add to archive (can be an mbox with many messages) while writing a file to the archive (which typically compresses, which takes time).
 
 	if mailboxOpt != "" && len(messageIDsOpt) != 0 {
 		return fmt.Errorf("cannot have both mailbox and message ids")
 	}
+	switch format {
+	case ExportMbox, ExportMaildir:
+	case ExportMaildirPP:
+		if len(messageIDsOpt) != 0 {
+			return fmt.Errorf("cannot export message ids in maildir++ format")
+		}
+	default:
+		return fmt.Errorf("unknown export format %q", format)
+	}
 
 	// Start transaction without closure, we are going to close it early, but don't
 	// want to deal with declaring many variables now to be able to assign them in a
@@ -161,7 +190,7 @@ func ExportMessages(ctx context.Context, log mlog.Log, db *bstore.DB, accountDir
 
 	if messageIDsOpt != nil {
 		var err error
-		errors, err = exportMessages(log, tx, accountDir, messageIDsOpt, archiver, maildir, start)
+		errors, err = exportMessages(log, tx, accountDir, messageIDsOpt, archiver, format, start)
 		if err != nil {
 			return fmt.Errorf("exporting messages: %v", err)
 		}
@@ -179,12 +208,14 @@ func ExportMessages(ctx context.Context, log mlog.Log, db *bstore.DB, accountDir
 			return mailboxOpt == "" || mb.Name == mailboxOpt || recursive && strings.HasPrefix(mb.Name, prefix)
 		})
 		q.SortAsc("Name")
+		exported := map[string]string{} // Mailbox name to name in export.
 		err = q.ForEach(func(mb Mailbox) error {
 			mailboxName := mb.Name
 			if trimPrefix != "" {
 				mailboxName = strings.TrimPrefix(mailboxName, trimPrefix)
 			}
-			errmsgs, err := exportMailbox(log, tx, accountDir, mb.ID, mailboxName, archiver, maildir, start)
+			exported[mb.Name] = mailboxName
+			errmsgs, err := exportMailbox(log, tx, accountDir, mb, mailboxName, archiver, format, start)
 			if err != nil {
 				return err
 			}
@@ -194,6 +225,12 @@ func ExportMessages(ctx context.Context, log mlog.Log, db *bstore.DB, accountDir
 		if err != nil {
 			return fmt.Errorf("query mailboxes: %w", err)
 		}
+
+		if format == ExportMaildirPP {
+			if err := exportSubscriptions(log, tx, archiver, exported, start); err != nil {
+				return err
+			}
+		}
 	}
 
 	if errors != "" {
@@ -215,8 +252,58 @@ func ExportMessages(ctx context.Context, log mlog.Log, db *bstore.DB, accountDir
 	return nil
 }
 
-func exportMessages(log mlog.Log, tx *bstore.Tx, accountDir string, messageIDs []int64, archiver Archiver, maildir bool, start time.Time) (string, error) {
-	mbe, err := newMailboxExport(log, "Export", accountDir, archiver, start, maildir)
+// exportSubscriptions writes a Dovecot subscriptions file for the subscribed
+// mailboxes among the exported mailboxes, with exported maps mailbox names to
+// their (possibly trimmed) name in the export.
+func exportSubscriptions(log mlog.Log, tx *bstore.Tx, archiver Archiver, exported map[string]string, start time.Time) error {
+	// Version 2 format, with mailbox hierarchy components separated by tabs.
+	b := bytes.NewBufferString("V\t2\n\n")
+	err := bstore.QueryTx[Subscription](tx).ForEach(func(sub Subscription) error {
+		name, ok := exported[sub.Name]
+		if !ok {
+			return nil
+		}
+		if name == "Inbox" {
+			name = "INBOX"
+		} else if strings.HasPrefix(name, "Inbox/") {
+			name = "INBOX" + name[len("Inbox"):]
+		}
+		b.WriteString(strings.ReplaceAll(name, "/", "\t") + "\n")
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("listing subscriptions: %v", err)
+	}
+	w, err := archiver.Create("subscriptions", int64(b.Len()), start)
+	if err != nil {
+		return fmt.Errorf("adding subscriptions: %v", err)
+	}
+	if _, err := w.Write(b.Bytes()); err != nil {
+		xerr := w.Close()
+		log.Check(xerr, "closing subscriptions file after error")
+		return fmt.Errorf("writing subscriptions: %v", err)
+	}
+	return w.Close()
+}
+
+// maildirPPDir returns the directory for a mailbox in a maildir++ layout, either
+// empty for the inbox, or a name starting with a dot, with a trailing slash.
+func maildirPPDir(mailboxName string) string {
+	if mailboxName == "Inbox" {
+		return ""
+	}
+	if strings.HasPrefix(mailboxName, "Inbox/") {
+		mailboxName = "INBOX" + mailboxName[len("Inbox"):]
+	}
+	l := strings.Split(mailboxName, "/")
+	for i, e := range l {
+		l[i] = strings.ReplaceAll(e, ".", `\2e`)
+	}
+	return "." + strings.Join(l, ".") + "/"
+}
+
+func exportMessages(log mlog.Log, tx *bstore.Tx, accountDir string, messageIDs []int64, archiver Archiver, format ExportFormat, start time.Time) (string, error) {
+	mbe, err := newMailboxExport(log, Mailbox{Name: "Export"}, "Export", accountDir, archiver, start, format)
 	if err != nil {
 		return "", err
 	}
@@ -239,8 +326,8 @@ func exportMessages(log mlog.Log, tx *bstore.Tx, accountDir string, messageIDs [
 	return mbe.errors, err
 }
 
-func exportMailbox(log mlog.Log, tx *bstore.Tx, accountDir string, mailboxID int64, mailboxName string, archiver Archiver, maildir bool, start time.Time) (string, error) {
-	mbe, err := newMailboxExport(log, mailboxName, accountDir, archiver, start, maildir)
+func exportMailbox(log mlog.Log, tx *bstore.Tx, accountDir string, mb Mailbox, mailboxName string, archiver Archiver, format ExportFormat, start time.Time) (string, error) {
+	mbe, err := newMailboxExport(log, mb, mailboxName, accountDir, archiver, start, format)
 	if err != nil {
 		return "", err
 	}
@@ -248,7 +335,7 @@ func exportMailbox(log mlog.Log, tx *bstore.Tx, accountDir string, mailboxID int
 
 	// Fetch all messages for mailbox.
 	q := bstore.QueryTx[Message](tx)
-	q.FilterNonzero(Message{MailboxID: mailboxID})
+	q.FilterNonzero(Message{MailboxID: mb.ID})
 	q.FilterEqual("Expunged", false)
 	q.SortAsc("Received", "ID")
 	err = q.ForEach(func(m Message) error {
@@ -291,12 +378,15 @@ func (f *maildirFlags) Empty() bool {
 
 type mailboxExport struct {
 	log          mlog.Log
+	mailbox      Mailbox
 	mailboxName  string
 	accountDir   string
 	archiver     Archiver
 	start        time.Time
 	maildir      bool
+	maildirDir   string // Directory for the maildir, with trailing slash, or empty for maildir++ inbox.
 	maildirFlags *maildirFlags
+	uidlist      *bytes.Buffer // Lines for dovecot-uidlist, for maildir++.
 	mboxtmp      *os.File
 	mboxwriter   *bufio.Writer
 	errors       string
@@ -308,27 +398,41 @@ func (e *mailboxExport) Cleanup() {
 	}
 }
 
-func newMailboxExport(log mlog.Log, mailboxName, accountDir string, archiver Archiver, start time.Time, maildir bool) (*mailboxExport, error) {
+func newMailboxExport(log mlog.Log, mb Mailbox, mailboxName, accountDir string, archiver Archiver, start time.Time, format ExportFormat) (*mailboxExport, error) {
 	mbe := mailboxExport{
 		log:         log,
+		mailbox:     mb,
 		mailboxName: mailboxName,
 		accountDir:  accountDir,
 		archiver:    archiver,
 		start:       start,
-		maildir:     maildir,
+		maildir:     format == ExportMaildir || format == ExportMaildirPP,
+		maildirDir:  mailboxName + "/",
+	}
+	if format == ExportMaildirPP {
+		mbe.maildirDir = maildirPPDir(mailboxName)
+		mbe.uidlist = &bytes.Buffer{}
 	}
-	if maildir {
+	if mbe.maildir {
 		// Create the directories that show this is a maildir.
 		mbe.maildirFlags = newMaildirFlags()
-		if _, err := archiver.Create(mailboxName+"/new/", 0, start); err != nil {
+		if _, err := archiver.Create(mbe.maildirDir+"new/", 0, start); err != nil {
 			return nil, fmt.Errorf("adding maildir new directory: %v", err)
 		}
-		if _, err := archiver.Create(mailboxName+"/cur/", 0, start); err != nil {
+		if _, err := archiver.Create(mbe.maildirDir+"cur/", 0, start); err != nil {
 			return nil, fmt.Errorf("adding maildir cur directory: %v", err)
 		}
-		if _, err := archiver.Create(mailboxName+"/tmp/", 0, start); err != nil {
+		if _, err := archiver.Create(mbe.maildirDir+"tmp/", 0, start); err != nil {
 			return nil, fmt.Errorf("adding maildir tmp directory: %v", err)
 		}
+		// Maildir++ subfolders are marked with an empty maildirfolder file.
+		if format == ExportMaildirPP && mbe.maildirDir != "" {
+			if w, err := archiver.Create(mbe.maildirDir+"maildirfolder", 0, start); err != nil {
+				return nil, fmt.Errorf("adding maildirfolder file: %v", err)
+			} else if err := w.Close(); err != nil {
+				return nil, fmt.Errorf("closing maildirfolder file: %v", err)
+			}
+		}
 	} else {
 		var err error
 		mbe.mboxtmp, err = os.CreateTemp("", "mox-mail-export-mbox")
@@ -356,26 +460,27 @@ func (e *mailboxExport) ExportMessage(m Message) error {
 			err := mf.Close()
 			e.log.Check(err, "closing message file after export")
 		}()
-		st, err := mf.Stat()
-		if err != nil {
-			e.errors += fmt.Sprintf("stat message file for id %d, path %s: %v (message skipped)\n", m.ID, mp, err)
+		fmr := MessageFileReader(m, mf)
+		if fmr.err != nil {
+			e.errors += fmt.Sprintf("reading message file for id %d, path %s: %v (message skipped)\n", m.ID, mp, fmr.err)
 			return nil
 		}
-		size := st.Size() + int64(len(m.MsgPrefix))
+		size := fmr.Size()
 		if size != m.Size {
-			e.errors += fmt.Sprintf("message size mismatch for message id %d, database has %d, size is %d+%d=%d, using calculated size\n", m.ID, m.Size, len(m.MsgPrefix), st.Size(), size)
+			e.errors += fmt.Sprintf("message size mismatch for message id %d, database has %d, size is %d+%d=%d, using calculated size\n", m.ID, m.Size, len(m.MsgPrefix), size-int64(len(m.MsgPrefix)), size)
 		}
-		mr = FileMsgReader(m.MsgPrefix, mf)
+		mr = fmr
 	}
 
 	if e.maildir {
-		p := e.mailboxName
+		p := e.maildirDir
 		if m.Flags.Seen {
-			p = filepath.Join(p, "cur")
+			p += "cur"
 		} else {
-			p = filepath.Join(p, "new")
+			p += "new"
 		}
-		name := fmt.Sprintf("%d.%d.mox:2,", m.Received.Unix(), m.ID)
+		base := fmt.Sprintf("%d.%d.mox", m.Received.Unix(), m.ID)
+		name := base + ":2,"
 
 		// Standard flags. May need to be sorted.
 		if m.Flags.Draft {
@@ -413,6 +518,10 @@ func (e *mailboxExport) ExportMessage(m Message) error {
 
 		p = filepath.Join(p, name)
 
+		if e.uidlist != nil {
+			fmt.Fprintf(e.uidlist, "%d :%s\n", m.UID, base)
+		}
+
 		// We store messages with \r\n, maildir needs without. But we need to know the
 		// final size. So first convert, then create file with size, and write from buffer.
 		// todo: for large messages, we should go through a temporary file instead of memory.
@@ -552,8 +661,32 @@ func (e *mailboxExport) ExportMessage(m Message) error {
 	return nil
 }
 
+// writeFile adds a file with buf as contents in the maildir.
+func (e *mailboxExport) writeFile(name string, buf []byte) error {
+	w, err := e.archiver.Create(e.maildirDir+name, int64(len(buf)), e.start)
+	if err != nil {
+		return fmt.Errorf("adding %s: %v", name, err)
+	}
+	if _, err := w.Write(buf); err != nil {
+		xerr := w.Close()
+		e.log.Check(xerr, "closing file after error", slog.String("name", name))
+		return fmt.Errorf("writing %s: %v", name, err)
+	}
+	return w.Close()
+}
+
 func (e *mailboxExport) Finish() error {
 	if e.maildir {
+		if e.uidlist != nil {
+			// Version 3 format, with UIDVALIDITY and next UID in the header line.
+			var b bytes.Buffer
+			fmt.Fprintf(&b, "3 V%d N%d\n", e.mailbox.UIDValidity, e.mailbox.UIDNext)
+			b.Write(e.uidlist.Bytes())
+			if err := e.writeFile("dovecot-uidlist", b.Bytes()); err != nil {
+				return err
+			}
+		}
+
 		if e.maildirFlags.Empty() {
 			return nil
 		}
@@ -564,16 +697,7 @@ func (e *mailboxExport) Finish() error {
 				return err
 			}
 		}
-		w, err := e.archiver.Create(e.mailboxName+"/dovecot-keywords", int64(b.Len()), e.start)
-		if err != nil {
-			return fmt.Errorf("adding dovecot-keywords: %v", err)
-		}
-		if _, err := w.Write(b.Bytes()); err != nil {
-			xerr := w.Close()
-			e.log.Check(xerr, "closing dovecot-keywords file after closing")
-			return fmt.Errorf("writing dovecot-keywords: %v", err)
-		}
-		return w.Close()
+		return e.writeFile("dovecot-keywords", b.Bytes())
 	}
 
 	if err := e.mboxwriter.Flush(); err != nil {
@@ -4,13 +4,17 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
+	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mjl-/bstore"
+
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 )
@@ -54,25 +58,32 @@ func TestExport(t *testing.T) {
 
 	var maildirZip, maildirTar, mboxZip, mboxTar bytes.Buffer
 
-	archive := func(archiver Archiver, mailbox string, messageIDs []int64, maildir bool) {
+	archive := func(archiver Archiver, mailbox string, messageIDs []int64, format ExportFormat) {
 		t.Helper()
-		err = ExportMessages(ctxbg, log, acc.DB, acc.Dir, archiver, maildir, mailbox, messageIDs, true)
+		err = ExportMessages(ctxbg, log, acc.DB, acc.Dir, archiver, format, mailbox, messageIDs, true)
 		tcheck(t, err, "export messages")
 		err = archiver.Close()
 		tcheck(t, err, "archiver close")
 	}
 
 	os.RemoveAll("../testdata/exportmaildir")
+	os.RemoveAll("../testdata/exportmaildirpp")
 	os.RemoveAll("../testdata/exportmbox")
 
-	archive(ZipArchiver{zip.NewWriter(&maildirZip)}, "", nil, true)
-	archive(ZipArchiver{zip.NewWriter(&mboxZip)}, "", nil, false)
-	archive(TarArchiver{tar.NewWriter(&maildirTar)}, "", nil, true)
-	archive(TarArchiver{tar.NewWriter(&mboxTar)}, "", nil, false)
-	archive(TarArchiver{tar.NewWriter(&mboxTar)}, "Inbox", nil, false)
-	archive(TarArchiver{tar.NewWriter(&mboxTar)}, "", []int64{m.ID}, false)
-	archive(DirArchiver{filepath.FromSlash("../testdata/exportmaildir")}, "", nil, true)
-	archive(DirArchiver{filepath.FromSlash("../testdata/exportmbox")}, "", nil, false)
+	archive(ZipArchiver{zip.NewWriter(&maildirZip)}, "", nil, ExportMaildir)
+	archive(ZipArchiver{zip.NewWriter(&mboxZip)}, "", nil, ExportMbox)
+	archive(TarArchiver{tar.NewWriter(&maildirTar)}, "", nil, ExportMaildir)
+	archive(TarArchiver{tar.NewWriter(&mboxTar)}, "", nil, ExportMbox)
+	archive(TarArchiver{tar.NewWriter(&mboxTar)}, "Inbox", nil, ExportMbox)
+	archive(TarArchiver{tar.NewWriter(&mboxTar)}, "", []int64{m.ID}, ExportMbox)
+	archive(DirArchiver{filepath.FromSlash("../testdata/exportmaildir")}, "", nil, ExportMaildir)
+	archive(DirArchiver{filepath.FromSlash("../testdata/exportmaildirpp")}, "", nil, ExportMaildirPP)
+	archive(DirArchiver{filepath.FromSlash("../testdata/exportmbox")}, "", nil, ExportMbox)
+
+	err = ExportMessages(ctxbg, log, acc.DB, acc.Dir, DirArchiver{filepath.FromSlash("../testdata/exportmaildirpp")}, ExportMaildirPP, "", []int64{m.ID}, false)
+	if err == nil {
+		t.Fatalf("exporting message ids in maildir++ format succeeded")
+	}
 
 	const defaultMailboxes = 6 // Inbox, Drafts, etc
 	if r, err := zip.NewReader(bytes.NewReader(maildirZip.Bytes()), int64(maildirZip.Len())); err != nil {
@@ -128,4 +139,38 @@ func TestExport(t *testing.T) {
 
 	checkDirFiles(filepath.FromSlash("../testdata/exportmaildir"), 2)
 	checkDirFiles(filepath.FromSlash("../testdata/exportmbox"), defaultMailboxes)
+	// 2 messages, uidlist per mailbox, maildirfolder per mailbox except inbox,
+	// subscriptions.
+	checkDirFiles(filepath.FromSlash("../testdata/exportmaildirpp"), 2+defaultMailboxes+defaultMailboxes-1+1)
+
+	// Inbox is at the root, with its uidlist, other mailboxes in dot directories.
+	readFile := func(name string) string {
+		t.Helper()
+		buf, err := os.ReadFile(filepath.Join(filepath.FromSlash("../testdata/exportmaildirpp"), name))
+		tcheck(t, err, "read file")
+		return string(buf)
+	}
+	var inbox Mailbox
+	acc.WithRLock(func() {
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			var err error
+			inbox, err = bstore.QueryTx[Mailbox](tx).FilterNonzero(Mailbox{Name: "Inbox"}).Get()
+			return err
+		})
+		tcheck(t, err, "get inbox")
+	})
+	uidlist := readFile("dovecot-uidlist")
+	if !strings.HasPrefix(uidlist, fmt.Sprintf("3 V%d N%d\n1 :", inbox.UIDValidity, inbox.UIDNext)) {
+		t.Fatalf("unexpected dovecot-uidlist for inbox: %q", uidlist)
+	}
+	if s := readFile(".Trash/dovecot-uidlist"); !strings.Contains(s, "\n1 :") {
+		t.Fatalf("unexpected dovecot-uidlist for trash: %q", s)
+	}
+	if s := readFile("subscriptions"); !strings.HasPrefix(s, "V\t2\n\n") || !strings.Contains(s, "\nINBOX\n") || !strings.Contains(s, "\nTrash\n") {
+		t.Fatalf("unexpected subscriptions: %q", s)
+	}
+
+	tcompare(t, maildirPPDir("Inbox"), "")
+	tcompare(t, maildirPPDir("Inbox/sub"), ".INBOX.sub/")
+	tcompare(t, maildirPPDir("Archive/v1.2"), `.Archive.v1\2e2/`)
 }
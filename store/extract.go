@@ -0,0 +1,132 @@
+package store
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+)
+
+// TextExtractor returns the text of an attachment, for searching messages.
+// Extractors are registered with RegisterTextExtractor. Built-in extractors are
+// registered for PDF, Office Open XML (docx, xlsx, pptx) and plain text files.
+type TextExtractor interface {
+	// ExtractText returns the text in data, the decoded attachment. Data is
+	// untrusted. The returned text is used as is, also when an error is returned.
+	ExtractText(data []byte) (string, error)
+}
+
+// TextExtractorFunc is a function implementing TextExtractor.
+type TextExtractorFunc func(data []byte) (string, error)
+
+// ExtractText calls fn.
+func (fn TextExtractorFunc) ExtractText(data []byte) (string, error) {
+	return fn(data)
+}
+
+var (
+	// Attachments larger than ExtractMaxSize bytes after decoding are not
+	// searched.
+	ExtractMaxSize int64 = 20 * 1024 * 1024
+
+	// At most ExtractMaxText bytes of text are used from an attachment.
+	ExtractMaxText = 1024 * 1024
+)
+
+var textExtractors = struct {
+	sync.Mutex
+	mediaTypes map[string]TextExtractor // Lower case, e.g. "application/pdf".
+	extensions map[string]TextExtractor // Lower case, with dot, e.g. ".pdf".
+}{
+	mediaTypes: map[string]TextExtractor{},
+	extensions: map[string]TextExtractor{},
+}
+
+// RegisterTextExtractor registers e for extracting text from attachments with
+// one of mediaTypes, e.g. "application/pdf", or with a filename with one of
+// extensions, e.g. ".pdf". Extensions are only used for attachments without a
+// registered media type, e.g. with a generic "application/octet-stream". A
+// previously registered extractor for a media type or extension is replaced.
+func RegisterTextExtractor(e TextExtractor, mediaTypes, extensions []string) {
+	textExtractors.Lock()
+	defer textExtractors.Unlock()
+	for _, mt := range mediaTypes {
+		textExtractors.mediaTypes[strings.ToLower(mt)] = e
+	}
+	for _, ext := range extensions {
+		textExtractors.extensions[strings.ToLower(ext)] = e
+	}
+}
+
+func init() {
+	RegisterTextExtractor(TextExtractorFunc(extractPDF), []string{"application/pdf", "application/x-pdf"}, []string{".pdf"})
+	RegisterTextExtractor(TextExtractorFunc(extractOOXML), []string{
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	}, []string{".docx", ".xlsx", ".pptx"})
+	RegisterTextExtractor(TextExtractorFunc(extractPlain), nil, []string{".txt", ".csv", ".md", ".log"})
+}
+
+// textExtractor returns the registered extractor for the non-text part p, or nil.
+func textExtractor(p *message.Part) TextExtractor {
+	textExtractors.Lock()
+	defer textExtractors.Unlock()
+	if e, ok := textExtractors.mediaTypes[strings.ToLower(p.MediaType+"/"+p.MediaSubType)]; ok {
+		return e
+	}
+	if _, filename, _ := p.DispositionFilename(); filename != "" {
+		return textExtractors.extensions[strings.ToLower(filepath.Ext(filename))]
+	}
+	return nil
+}
+
+// extractPartText returns the text of the non-text leaf part p, if an extractor
+// is registered for it and p is not too large.
+func extractPartText(log mlog.Log, p *message.Part) (text string, ok bool) {
+	e := textExtractor(p)
+	if e == nil || p.DecodedSize > ExtractMaxSize {
+		return "", false
+	}
+	data, err := io.ReadAll(io.LimitReader(p.Reader(), ExtractMaxSize+1))
+	if err != nil {
+		log.Debugx("reading attachment for extracting text", err)
+		return "", false
+	} else if int64(len(data)) > ExtractMaxSize {
+		return "", false
+	}
+
+	defer func() {
+		x := recover()
+		if x != nil {
+			log.Error("unhandled panic extracting text from attachment", slog.Any("err", x))
+			debug.PrintStack()
+			metrics.PanicInc(metrics.Store)
+			text, ok = "", false
+		}
+	}()
+	text, err = e.ExtractText(data)
+	if err != nil {
+		log.Debugx("extracting text from attachment", err, slog.String("mediatype", p.MediaType+"/"+p.MediaSubType))
+	}
+	if len(text) > ExtractMaxText {
+		text = strings.ToValidUTF8(text[:ExtractMaxText], "")
+	}
+	return text, text != ""
+}
+
+// extractPlain returns data as text if it is valid utf-8.
+func extractPlain(data []byte) (string, error) {
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("not utf-8")
+	}
+	return string(data), nil
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+)
+
+func testPDF(t *testing.T, content string) []byte {
+	t.Helper()
+	var zbuf bytes.Buffer
+	zw := zlib.NewWriter(&zbuf)
+	_, err := zw.Write([]byte(content))
+	tcheck(t, err, "compress")
+	err = zw.Close()
+	tcheck(t, err, "close compressor")
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	b.WriteString("1 0 obj\n<< /Type /Page /Contents 2 0 R >>\nendobj\n")
+	fmt.Fprintf(&b, "2 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n", zbuf.Len())
+	b.Write(zbuf.Bytes())
+	b.WriteString("\nendstream\nendobj\n")
+	b.WriteString("3 0 obj\n<< /Subtype /Image /Length 3 >>\nstream\n(x) Tj\nendstream\nendobj\n")
+	b.WriteString("%%EOF\n")
+	return b.Bytes()
+}
+
+func testOOXML(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	zw := zip.NewWriter(&b)
+	for name, data := range files {
+		w, err := zw.Create(name)
+		tcheck(t, err, "create zip file")
+		_, err = w.Write([]byte(data))
+		tcheck(t, err, "write zip file")
+	}
+	err := zw.Close()
+	tcheck(t, err, "close zip")
+	return b.Bytes()
+}
+
+func TestExtractText(t *testing.T) {
+	pdf := testPDF(t, `BT /F1 12 Tf 72 700 Td (Invoice for project) Tj 0 -14 Td [(Zan) 20 (zibar) -300 (\(draft\))] TJ ET
+BT 72 600 Td <FEFF00630061006600E9> Tj (total\0405) ' ET`)
+	text, err := extractPDF(pdf)
+	tcheck(t, err, "extract pdf")
+	tcompare(t, text, "Invoice for project Zanzibar (draft)\ncafé\ntotal 5\n")
+
+	_, err = extractPDF([]byte("not a pdf"))
+	if err == nil {
+		t.Fatalf("expected error for non-pdf")
+	}
+
+	docx := testOOXML(t, map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/document.xml":   `<w:document xmlns:w="x"><w:body><w:p><w:r><w:t>Project</w:t></w:r><w:r><w:t xml:space="preserve"> X &amp; Y</w:t></w:r></w:p><w:p><w:r><w:t>Second</w:t></w:r></w:p></w:body></w:document>`,
+	})
+	text, err = extractOOXML(docx)
+	tcheck(t, err, "extract docx")
+	tcompare(t, text, "Project X & Y\nSecond\n")
+
+	xlsx := testOOXML(t, map[string]string{
+		"xl/sharedStrings.xml":     `<sst><si><t>Budget</t></si><si><t>Marketing</t></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c t="s"><v>0</v></c><c t="inlineStr"><is><t>Inline</t></is></c></row></sheetData></worksheet>`,
+	})
+	text, err = extractOOXML(xlsx)
+	tcheck(t, err, "extract xlsx")
+	if !strings.Contains(text, "Budget\nMarketing\n") || !strings.Contains(text, "Inline\n") || strings.Contains(text, "0") {
+		t.Fatalf("unexpected xlsx text %q", text)
+	}
+
+	// Searching messages finds text in attachments.
+	log := mlog.New("store", nil)
+	msg := strings.ReplaceAll(`Subject: invoice
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=x
+
+--x
+Content-Type: text/plain
+
+See attached.
+--x
+Content-Type: application/octet-stream
+Content-Disposition: attachment; filename="invoice.PDF"
+Content-Transfer-Encoding: base64
+
+`+base64.StdEncoding.EncodeToString(pdf)+`
+--x
+Content-Type: application/vnd.openxmlformats-officedocument.wordprocessingml.document
+Content-Transfer-Encoding: base64
+
+`+base64.StdEncoding.EncodeToString(docx)+`
+--x--
+`, "\n", "\r\n")
+	p, err := message.EnsurePart(log.Logger, false, strings.NewReader(msg), int64(len(msg)))
+	tcheck(t, err, "parse message")
+
+	match := func(words ...string) bool {
+		t.Helper()
+		ws := PrepareWordSearch(words, nil)
+		ok, err := ws.MatchPart(log, &p, true)
+		tcheck(t, err, "match part")
+		return ok
+	}
+	tcompare(t, match("zanzibar"), true)
+	tcompare(t, match("project x"), true)
+	tcompare(t, match("notpresent"), false)
+
+	words := searchIndexMessageWords(log, &p)
+	for _, w := range []string{"zanzibar", "invoice", "café", "second"} {
+		if _, ok := words[w]; !ok {
+			t.Fatalf("word %q not in index words", w)
+		}
+	}
+
+	// Large attachments are not extracted.
+	defer func(v int64) {
+		ExtractMaxSize = v
+	}(ExtractMaxSize)
+	ExtractMaxSize = 100
+	tcompare(t, match("zanzibar"), false)
+}
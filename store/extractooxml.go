@@ -0,0 +1,81 @@
+package store
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// extractOOXML returns the text of an Office Open XML document: a zip file with
+// XML files. We use the text elements of Word documents, the shared and inline
+// strings of Excel spreadsheets, and the text of PowerPoint slides.
+func extractOOXML(data []byte) (string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return "", fmt.Errorf("opening zip file: %v", err)
+	}
+
+	var sb strings.Builder
+	var errs []error
+	for _, f := range zr.File {
+		switch {
+		case f.Name == "word/document.xml",
+			f.Name == "xl/sharedStrings.xml",
+			path.Dir(f.Name) == "xl/worksheets" && path.Ext(f.Name) == ".xml",
+			path.Dir(f.Name) == "ppt/slides" && path.Ext(f.Name) == ".xml":
+		default:
+			continue
+		}
+		if err := ooxmlText(&sb, f); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Name, err))
+		}
+		if sb.Len() > ExtractMaxText {
+			break
+		}
+	}
+	return sb.String(), errors.Join(errs...)
+}
+
+// ooxmlText writes the character data of "t" elements, e.g. w:t, a:t and t, of
+// the XML file to sb. Paragraphs and cells end with a newline.
+func ooxmlText(sb *strings.Builder, f *zip.File) error {
+	fr, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer fr.Close()
+
+	// Compressed files can be much larger than the attachment, limit what we read.
+	d := xml.NewDecoder(io.LimitReader(fr, ExtractMaxSize))
+	var intext bool
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			intext = t.Name.Local == "t"
+		case xml.EndElement:
+			intext = false
+			switch t.Name.Local {
+			case "p", "si", "c":
+				sb.WriteString("\n")
+			}
+		case xml.CharData:
+			if intext {
+				sb.Write(t)
+				if sb.Len() > ExtractMaxText {
+					return nil
+				}
+			}
+		}
+	}
+}
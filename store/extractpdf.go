@@ -0,0 +1,313 @@
+package store
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+)
+
+// extractPDF returns the text of a PDF file. This is a best-effort extractor, it
+// does not parse the PDF object structure. It finds the streams, decompresses
+// them when needed, and gathers the strings shown by text operators in them.
+// Text in fonts with custom encodings, e.g. many CID fonts, is not extracted
+// correctly, and encrypted PDFs are not supported.
+func extractPDF(data []byte) (string, error) {
+	if !bytes.HasPrefix(data, []byte("%PDF-")) {
+		return "", errors.New("not a pdf file")
+	}
+
+	var sb strings.Builder
+	var errs []error
+	off := 0
+	for sb.Len() <= ExtractMaxText {
+		i := bytes.Index(data[off:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := off + i
+		off = start + len("stream")
+
+		// The keyword must follow the dictionary of the stream object, not be the end of
+		// "endstream".
+		dictEnd := bytes.LastIndex(data[:start], []byte(">>"))
+		if dictEnd < 0 || len(bytes.TrimSpace(data[dictEnd+2:start])) != 0 {
+			continue
+		}
+		dictStart := bytes.LastIndex(data[:dictEnd], []byte("obj"))
+		if dictStart < 0 {
+			continue
+		}
+		dict := data[dictStart:dictEnd]
+
+		// Stream data starts after the end of line.
+		if bytes.HasPrefix(data[off:], []byte("\r\n")) {
+			off += 2
+		} else if bytes.HasPrefix(data[off:], []byte("\n")) || bytes.HasPrefix(data[off:], []byte("\r")) {
+			off++
+		}
+		end := bytes.Index(data[off:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		body := data[off : off+end]
+		off += end + len("endstream")
+
+		content, ok, err := pdfStreamData(dict, body)
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if ok {
+			pdfContentText(&sb, content)
+		}
+	}
+	return sb.String(), errors.Join(errs...)
+}
+
+// pdfStreamData returns the decoded data of a stream that could be a content
+// stream. For streams with images, fonts, or unsupported filters, ok is false.
+func pdfStreamData(dict, body []byte) (data []byte, ok bool, rerr error) {
+	for _, s := range []string{"/Image", "/Length1", "/XRef", "/Metadata", "/DCTDecode", "/JPXDecode", "/CCITTFaxDecode", "/JBIG2Decode", "/ASCII85Decode", "/ASCIIHexDecode", "/LZWDecode", "/RunLengthDecode", "/Crypt"} {
+		if bytes.Contains(dict, []byte(s)) {
+			return nil, false, nil
+		}
+	}
+	if !bytes.Contains(dict, []byte("/Filter")) {
+		return body, true, nil
+	} else if !bytes.Contains(dict, []byte("/FlateDecode")) {
+		return nil, false, nil
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, false, fmt.Errorf("decompressing stream: %v", err)
+	}
+	// The data read before an error, e.g. for a truncated stream, is still used.
+	data, err = io.ReadAll(io.LimitReader(zr, ExtractMaxSize))
+	if err != nil {
+		err = fmt.Errorf("decompressing stream: %v", err)
+	}
+	return data, len(data) > 0, err
+}
+
+// pdfContentText writes the strings shown by the text operators in content to sb.
+func pdfContentText(sb *strings.Builder, content []byte) {
+	var strs []string // String operands for the next operator.
+	var inArray bool  // In array, for the TJ operator.
+	var moved bool    // Whether text position changed since last shown string.
+	var shown bool    // Whether text was shown in the current text object.
+	space := func() {
+		if sb.Len() > 0 {
+			if s := sb.String(); !strings.HasSuffix(s, " ") && !strings.HasSuffix(s, "\n") {
+				sb.WriteString(" ")
+			}
+		}
+	}
+
+	isDelim := func(c byte) bool {
+		return strings.IndexByte("()<>[]{}/%", c) >= 0 || c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0
+	}
+
+	for o := 0; o < len(content) && sb.Len() <= ExtractMaxText; {
+		c := content[o]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f' || c == 0:
+			o++
+		case c == '%':
+			for o < len(content) && content[o] != '\r' && content[o] != '\n' {
+				o++
+			}
+		case c == '(':
+			var s []byte
+			s, o = pdfLiteralString(content, o+1)
+			strs = append(strs, pdfDecodeString(s))
+		case c == '<' && o+1 < len(content) && content[o+1] == '<', c == '>' && o+1 < len(content) && content[o+1] == '>':
+			o += 2
+		case c == '<':
+			e := bytes.IndexByte(content[o:], '>')
+			if e < 0 {
+				return
+			}
+			strs = append(strs, pdfDecodeString(pdfHexString(content[o+1:o+e])))
+			o += e + 1
+		case c == '[':
+			inArray = true
+			o++
+		case c == ']':
+			inArray = false
+			o++
+		case c == '/':
+			o++
+			for o < len(content) && !isDelim(content[o]) {
+				o++
+			}
+		default:
+			s := o
+			for o < len(content) && !isDelim(content[o]) {
+				o++
+			}
+			if o == s {
+				// Unexpected delimiter, e.g. "{", ")" or ">".
+				o++
+				continue
+			}
+			tok := string(content[s:o])
+			if c == '+' || c == '-' || c == '.' || c >= '0' && c <= '9' {
+				// Number. Large negative adjustments in TJ arrays separate words.
+				if v, err := strconv.ParseFloat(tok, 64); err == nil && inArray && v < -200 {
+					strs = append(strs, " ")
+				}
+				continue
+			}
+
+			switch tok {
+			case "Tj", "TJ", "'", `"`:
+				if tok == "'" || tok == `"` {
+					sb.WriteString("\n")
+				} else if moved {
+					space()
+				}
+				for _, str := range strs {
+					sb.WriteString(str)
+				}
+				moved = false
+				shown = true
+			case "Td", "TD", "Tm", "T*":
+				moved = true
+			case "BT":
+				shown = false
+			case "ET":
+				if shown {
+					sb.WriteString("\n")
+				}
+				moved = false
+			case "ID":
+				// Inline image data, skip until end marker.
+				e := bytes.Index(content[o:], []byte("EI"))
+				if e < 0 {
+					return
+				}
+				o += e + 2
+			}
+			strs = strs[:0]
+		}
+	}
+}
+
+// pdfLiteralString parses a literal string starting at o, after the opening
+// parenthesis, and returns the string and the offset after the closing
+// parenthesis.
+func pdfLiteralString(b []byte, o int) ([]byte, int) {
+	var s []byte
+	depth := 0
+	for o < len(b) {
+		c := b[o]
+		o++
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			if depth == 0 {
+				return s, o
+			}
+			depth--
+		case '\\':
+			if o >= len(b) {
+				return s, o
+			}
+			c = b[o]
+			o++
+			switch c {
+			case 'n':
+				c = '\n'
+			case 'r':
+				c = '\r'
+			case 't':
+				c = '\t'
+			case 'b':
+				c = '\b'
+			case 'f':
+				c = '\f'
+			case '\r':
+				// Line continuation.
+				if o < len(b) && b[o] == '\n' {
+					o++
+				}
+				continue
+			case '\n':
+				continue
+			default:
+				if c >= '0' && c <= '7' {
+					v := int(c - '0')
+					for i := 0; i < 2 && o < len(b) && b[o] >= '0' && b[o] <= '7'; i++ {
+						v = v*8 + int(b[o]-'0')
+						o++
+					}
+					c = byte(v)
+				}
+			}
+		}
+		s = append(s, c)
+	}
+	return s, o
+}
+
+// pdfHexString decodes a hexadecimal string, ignoring whitespace. A missing
+// final digit is zero.
+func pdfHexString(b []byte) []byte {
+	var s []byte
+	var v byte
+	var n int
+	for _, c := range b {
+		var d byte
+		switch {
+		case c >= '0' && c <= '9':
+			d = c - '0'
+		case c >= 'a' && c <= 'f':
+			d = c - 'a' + 10
+		case c >= 'A' && c <= 'F':
+			d = c - 'A' + 10
+		default:
+			continue
+		}
+		v = v<<4 | d
+		n++
+		if n == 2 {
+			s = append(s, v)
+			v, n = 0, 0
+		}
+	}
+	if n == 1 {
+		s = append(s, v<<4)
+	}
+	return s
+}
+
+// pdfDecodeString decodes a string as UTF-16BE if it has a byte order mark, and
+// otherwise as latin1, which is close to the common PDFDocEncoding and
+// WinAnsiEncoding. Control characters are removed.
+func pdfDecodeString(s []byte) string {
+	var r []rune
+	if len(s) >= 2 && s[0] == 0xfe && s[1] == 0xff {
+		u := make([]uint16, (len(s)-2)/2)
+		for i := range u {
+			u[i] = uint16(s[2+2*i])<<8 | uint16(s[2+2*i+1])
+		}
+		r = utf16.Decode(u)
+	} else {
+		r = make([]rune, len(s))
+		for i, c := range s {
+			r[i] = rune(c)
+		}
+	}
+	return strings.Map(func(c rune) rune {
+		if unicode.IsControl(c) && c != '\n' && c != '\t' {
+			return -1
+		}
+		return c
+	}, string(r))
+}
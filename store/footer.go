@@ -0,0 +1,208 @@
+package store
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"maps"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"strings"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+// ApplyOutgoingFooter adds the footer of the account, or otherwise of the domain of
+// msgFrom, to the text of the outgoing message in f. It must be called before DKIM
+// signing. Signed and encrypted messages are not changed. If the message was
+// changed, f is rewritten in place, and its new size is returned with changed set.
+func (a *Account) ApplyOutgoingFooter(log mlog.Log, msgFrom smtp.Address, f *os.File) (size int64, changed bool, rerr error) {
+	var footer *config.OutgoingFooter
+	if accConf, ok := a.Conf(); ok && accConf.OutgoingFooter != nil {
+		footer = accConf.OutgoingFooter
+	} else if confDom, ok := mox.Conf.Domain(msgFrom.Domain); ok {
+		footer = confDom.OutgoingFooter
+	}
+	if footer == nil {
+		return 0, false, nil
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, false, fmt.Errorf("stat message file: %v", err)
+	}
+	p, err := message.Parse(log.Logger, false, f)
+	if err != nil {
+		return 0, false, fmt.Errorf("parsing message: %v", err)
+	}
+	if err := p.Walk(log.Logger, nil); err != nil {
+		return 0, false, fmt.Errorf("parsing message parts: %v", err)
+	}
+	if footerSigned(p) {
+		log.Debug("not adding footer to signed or encrypted message", slog.String("account", a.Name))
+		return 0, false, nil
+	}
+	parts := footerTextParts(&p)
+	if len(parts) == 0 {
+		return 0, false, nil
+	}
+	log.Debug("adding footer to outgoing message", slog.String("account", a.Name), slog.Int("parts", len(parts)))
+
+	tmpf, err := CreateMessageTemp(log, "outgoing-footer")
+	if err != nil {
+		return 0, false, fmt.Errorf("creating temporary file: %v", err)
+	}
+	defer CloseRemoveTempFile(log, tmpf, "message with outgoing footer")
+
+	// Parts are in message order, we copy the data between them as is.
+	var offset int64
+	for _, tp := range parts {
+		if _, err := io.Copy(tmpf, io.NewSectionReader(f, offset, tp.HeaderOffset-offset)); err != nil {
+			return 0, false, fmt.Errorf("copying message data: %v", err)
+		}
+		buf, err := footerPart(f, tp, footer)
+		if err != nil {
+			return 0, false, err
+		}
+		if _, err := tmpf.Write(buf); err != nil {
+			return 0, false, fmt.Errorf("writing message part: %v", err)
+		}
+		offset = tp.EndOffset
+	}
+	if _, err := io.Copy(tmpf, io.NewSectionReader(f, offset, fi.Size()-offset)); err != nil {
+		return 0, false, fmt.Errorf("copying message data: %v", err)
+	}
+
+	size, err = replaceFileContents(f, tmpf)
+	if err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
+// footerSigned returns whether the message has signed or encrypted parts, which
+// would become invalid by adding a footer.
+func footerSigned(p message.Part) bool {
+	if p.MediaType == "MULTIPART" && (p.MediaSubType == "SIGNED" || p.MediaSubType == "ENCRYPTED") {
+		return true
+	}
+	if p.MediaType == "APPLICATION" && (p.MediaSubType == "PKCS7-MIME" || p.MediaSubType == "X-PKCS7-MIME") {
+		return true
+	}
+	for _, pp := range p.Parts {
+		if footerSigned(pp) {
+			return true
+		}
+	}
+	return false
+}
+
+// footerTextParts returns the text/plain and text/html parts that form the text
+// of the message, to which a footer is added. For multipart/alternative, each
+// alternative is used. For other multiparts, e.g. multipart/mixed with
+// attachments, only the first part is the message text.
+func footerTextParts(p *message.Part) []*message.Part {
+	switch {
+	case p.MediaType == "" || p.MediaType == "TEXT" && (p.MediaSubType == "PLAIN" || p.MediaSubType == "HTML"):
+		if p.ContentDisposition != nil && strings.HasPrefix(strings.ToLower(strings.TrimSpace(*p.ContentDisposition)), "attachment") {
+			return nil
+		}
+		return []*message.Part{p}
+	case p.MediaType == "MULTIPART" && p.MediaSubType == "ALTERNATIVE":
+		var l []*message.Part
+		for i := range p.Parts {
+			l = append(l, footerTextParts(&p.Parts[i])...)
+		}
+		return l
+	case p.MediaType == "MULTIPART" && len(p.Parts) > 0:
+		return footerTextParts(&p.Parts[0])
+	}
+	return nil
+}
+
+// footerPart returns the part with footer added, including its header. The text
+// is converted to utf-8, and encoded as quoted-printable if needed.
+func footerPart(f *os.File, p *message.Part, footer *config.OutgoingFooter) ([]byte, error) {
+	buf, err := io.ReadAll(p.ReaderUTF8OrBinary())
+	if err != nil {
+		return nil, fmt.Errorf("reading message part: %v", err)
+	}
+	text := string(buf)
+	if text != "" && !strings.HasSuffix(text, "\r\n") {
+		text += "\r\n"
+	}
+	if p.MediaSubType == "HTML" {
+		htmlFooter := footer.HTML
+		if htmlFooter == "" {
+			lines := make([]string, len(footer.Text))
+			for i, line := range footer.Text {
+				lines[i] = html.EscapeString(line)
+			}
+			htmlFooter = "<div>" + strings.Join(lines, "<br>\r\n") + "</div>"
+		}
+		if i := strings.LastIndex(strings.ToLower(text), "</body>"); i >= 0 {
+			text = text[:i] + htmlFooter + "\r\n" + text[i:]
+		} else {
+			text += htmlFooter + "\r\n"
+		}
+	} else {
+		text += "\r\n" + strings.Join(footer.Text, "\r\n") + "\r\n"
+	}
+
+	cte := "7bit"
+	if message.NeedsQuotedPrintable(text) || strings.ContainsFunc(text, func(c rune) bool { return c >= 0x80 }) {
+		var sb strings.Builder
+		qpw := quotedprintable.NewWriter(&sb)
+		if _, err := qpw.Write([]byte(text)); err != nil {
+			return nil, fmt.Errorf("encoding quoted-printable: %v", err)
+		}
+		if err := qpw.Close(); err != nil {
+			return nil, fmt.Errorf("encoding quoted-printable: %v", err)
+		}
+		text = sb.String()
+		cte = "quoted-printable"
+	}
+
+	ct := "text/plain"
+	if p.MediaType != "" {
+		ct = strings.ToLower(p.MediaType + "/" + p.MediaSubType)
+	}
+	params := maps.Clone(p.ContentTypeParams)
+	if params == nil {
+		params = map[string]string{}
+	}
+	params["charset"] = "utf-8"
+
+	// Keep the header of the part, replacing the content-type and
+	// content-transfer-encoding.
+	hdr := make([]byte, p.BodyOffset-p.HeaderOffset)
+	if _, err := f.ReadAt(hdr, p.HeaderOffset); err != nil {
+		return nil, fmt.Errorf("reading header of message part: %v", err)
+	}
+	hdr = []byte(strings.TrimSuffix(string(hdr), "\r\n"))
+	var nbuf []byte
+	haveMIMEVersion := false
+	for _, fl := range headerFields(hdr) {
+		switch headerFieldKey(fl) {
+		case "content-type", "content-transfer-encoding":
+			continue
+		case "mime-version":
+			haveMIMEVersion = true
+		}
+		nbuf = append(nbuf, fl...)
+	}
+	if p.BoundaryOffset < 0 && !haveMIMEVersion {
+		nbuf = append(nbuf, "MIME-Version: 1.0\r\n"...)
+	}
+	nbuf = append(nbuf, "Content-Type: "+mime.FormatMediaType(ct, params)+"\r\n"...)
+	nbuf = append(nbuf, "Content-Transfer-Encoding: "+cte+"\r\n"...)
+	nbuf = append(nbuf, "\r\n"...)
+	nbuf = append(nbuf, text...)
+	return nbuf, nil
+}
@@ -0,0 +1,170 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestOutgoingFooter(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	crlf := func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") }
+	msgFrom := smtp.NewAddress("mjl", dns.Domain{ASCII: "mox.example"})
+
+	// apply writes msg to a file, adds the footer, and returns the resulting message.
+	apply := func(msg string) (string, bool) {
+		t.Helper()
+		f, err := CreateMessageTemp(log, "footer-test")
+		tcheck(t, err, "create temp file")
+		defer CloseRemoveTempFile(log, f, "test message")
+		_, err = f.Write([]byte(crlf(msg)))
+		tcheck(t, err, "write message")
+		size, changed, err := acc.ApplyOutgoingFooter(log, msgFrom, f)
+		tcheck(t, err, "add footer")
+		buf, err := io.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+		tcheck(t, err, "read message")
+		if changed {
+			tcompare(t, size, int64(len(buf)))
+		}
+		return string(buf), changed
+	}
+
+	// partText returns the decoded text of the part at path.
+	partText := func(msg string, path ...int) string {
+		t.Helper()
+		p, err := message.Parse(log.Logger, true, strings.NewReader(msg))
+		tcheck(t, err, "parse message")
+		err = p.Walk(log.Logger, nil)
+		tcheck(t, err, "walk message")
+		for _, i := range path {
+			p = p.Parts[i]
+		}
+		buf, err := io.ReadAll(p.ReaderUTF8OrBinary())
+		tcheck(t, err, "read part")
+		return string(buf)
+	}
+
+	plain := `From: <mjl@mox.example>
+To: <remote@example.org>
+Subject: test
+
+hi
+`
+
+	// No footer configured.
+	_, changed := apply(plain)
+	tcompare(t, changed, false)
+
+	confDom, _ := mox.Conf.Domain(dns.Domain{ASCII: "mox.example"})
+	confDom.OutgoingFooter = &config.OutgoingFooter{Text: []string{"--", "Domain footer"}}
+	mox.Conf.Dynamic.Domains["mox.example"] = confDom
+	defer func() {
+		confDom.OutgoingFooter = nil
+		mox.Conf.Dynamic.Domains["mox.example"] = confDom
+	}()
+
+	got, changed := apply(plain)
+	tcompare(t, changed, true)
+	exp := crlf(`From: <mjl@mox.example>
+To: <remote@example.org>
+Subject: test
+MIME-Version: 1.0
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: 7bit
+
+hi
+
+--
+Domain footer
+`)
+	tcompare(t, got, exp)
+
+	// Account footer takes precedence over domain footer.
+	accConf, _ := acc.Conf()
+	accConf.OutgoingFooter = &config.OutgoingFooter{Text: []string{"Vertraulich – nur für den Empfänger."}}
+	mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	defer func() {
+		accConf.OutgoingFooter = nil
+		mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	}()
+
+	alternative := `From: <mjl@mox.example>
+To: <remote@example.org>
+Subject: test
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=outer
+
+--outer
+Content-Type: multipart/alternative; boundary=inner
+
+--inner
+Content-Type: text/plain; charset=iso-8859-1
+Content-Transfer-Encoding: quoted-printable
+
+caf=E9
+--inner
+Content-Type: text/html; charset=utf-8
+
+<html><body>hi</body></html>
+--inner--
+
+--outer
+Content-Type: text/plain
+Content-Disposition: attachment; filename=notes.txt
+
+attached
+--outer--
+`
+	got, changed = apply(alternative)
+	tcompare(t, changed, true)
+	tcompare(t, partText(got, 0, 0), "café\r\n\r\nVertraulich – nur für den Empfänger.\r\n")
+	tcompare(t, partText(got, 0, 1), "<html><body>hi<div>Vertraulich – nur für den Empfänger.</div>\r\n</body></html>\r\n")
+	tcompare(t, partText(got, 1), "attached")
+
+	// Signed messages are not changed.
+	signed := `From: <mjl@mox.example>
+To: <remote@example.org>
+Subject: test
+MIME-Version: 1.0
+Content-Type: multipart/signed; protocol="application/pgp-signature"; boundary=b
+
+--b
+Content-Type: text/plain
+
+hi
+--b
+Content-Type: application/pgp-signature
+
+sig
+--b--
+`
+	got, changed = apply(signed)
+	tcompare(t, changed, false)
+	tcompare(t, got, crlf(signed))
+}
@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+// ApplyOutgoingHeaderRules changes the header of the outgoing message in f
+// according to the outgoing header rules of the domain of msgFrom and of the
+// account. It must be called before DKIM signing. If the header was changed, f is
+// rewritten in place, and its new size is returned with changed set.
+func (a *Account) ApplyOutgoingHeaderRules(log mlog.Log, msgFrom smtp.Address, f *os.File) (size int64, changed bool, rerr error) {
+	var rules []*config.OutgoingHeaderRules
+	if confDom, ok := mox.Conf.Domain(msgFrom.Domain); ok && confDom.OutgoingHeaderRules != nil {
+		rules = append(rules, confDom.OutgoingHeaderRules)
+	}
+	if accConf, ok := a.Conf(); ok && accConf.OutgoingHeaderRules != nil {
+		rules = append(rules, accConf.OutgoingHeaderRules)
+	}
+	if len(rules) == 0 {
+		return 0, false, nil
+	}
+
+	br := bufio.NewReader(io.NewSectionReader(f, 0, math.MaxInt64))
+	hdr, err := message.ReadHeaders(br)
+	if err != nil {
+		return 0, false, fmt.Errorf("reading message header: %w", err)
+	}
+	nhdr := hdr
+	for _, r := range rules {
+		nhdr = outgoingHeaderRewrite(nhdr, r)
+	}
+	if bytes.Equal(nhdr, hdr) {
+		return 0, false, nil
+	}
+	log.Debug("applied outgoing header rules", slog.String("account", a.Name))
+
+	// We write the new message to a temporary file and copy it back, so callers can
+	// keep using their file.
+	tmpf, err := CreateMessageTemp(log, "outgoing-header-rules")
+	if err != nil {
+		return 0, false, fmt.Errorf("creating temporary file: %v", err)
+	}
+	defer CloseRemoveTempFile(log, tmpf, "message with outgoing header rules applied")
+	if _, err := tmpf.Write(nhdr); err != nil {
+		return 0, false, fmt.Errorf("writing message header: %v", err)
+	}
+	if _, err := tmpf.Write([]byte("\r\n")); err != nil {
+		return 0, false, fmt.Errorf("writing message header separator: %v", err)
+	}
+	if _, err := io.Copy(tmpf, br); err != nil {
+		return 0, false, fmt.Errorf("writing message body: %v", err)
+	}
+
+	size, err = replaceFileContents(f, tmpf)
+	if err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
+// replaceFileContents replaces the contents of f with those of tmpf.
+func replaceFileContents(f, tmpf *os.File) (int64, error) {
+	if err := f.Truncate(0); err != nil {
+		return 0, fmt.Errorf("truncating message file: %v", err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return 0, fmt.Errorf("seek in message file: %v", err)
+	}
+	if _, err := tmpf.Seek(0, 0); err != nil {
+		return 0, fmt.Errorf("seek in temporary file: %v", err)
+	}
+	size, err := io.Copy(f, tmpf)
+	if err != nil {
+		return 0, fmt.Errorf("writing message file: %v", err)
+	}
+	return size, nil
+}
+
+// headerFields splits a message header into header fields, each including its
+// continuation lines and trailing crlf.
+func headerFields(hdr []byte) [][]byte {
+	var fields [][]byte
+	for len(hdr) > 0 {
+		n := 0
+		for {
+			i := bytes.Index(hdr[n:], []byte("\r\n"))
+			if i < 0 {
+				n = len(hdr)
+				break
+			}
+			n += i + 2
+			if n >= len(hdr) || hdr[n] != ' ' && hdr[n] != '\t' {
+				break
+			}
+		}
+		fields = append(fields, hdr[:n])
+		hdr = hdr[n:]
+	}
+	return fields
+}
+
+// headerFieldKey returns the lower-case key of a header field.
+func headerFieldKey(f []byte) string {
+	k, _, _ := bytes.Cut(f, []byte(":"))
+	return strings.ToLower(strings.TrimSpace(string(k)))
+}
+
+// outgoingHeaderRewrite returns hdr, a message header ending with a single crlf,
+// with the rules applied.
+func outgoingHeaderRewrite(hdr []byte, r *config.OutgoingHeaderRules) []byte {
+	fields := headerFields(hdr)
+	matchRemove := func(key string) bool {
+		for _, k := range r.Remove {
+			k = strings.ToLower(k)
+			if prefix, ok := strings.CutSuffix(k, "*"); ok && strings.HasPrefix(key, prefix) || key == k {
+				return true
+			}
+		}
+		return false
+	}
+
+	type addField struct {
+		key   string
+		value string
+	}
+	var add []addField
+	if r.ReplyTo != "" {
+		if a, err := mail.ParseAddress(r.ReplyTo); err == nil {
+			add = append(add, addField{"reply-to", "Reply-To: " + a.String() + "\r\n"})
+		}
+	}
+	for _, h := range r.Add {
+		k, v, _ := strings.Cut(h, ":")
+		v = strings.TrimSpace(v)
+		for _, c := range v {
+			if c >= 0x80 {
+				v = mime.QEncoding.Encode("utf-8", v)
+				break
+			}
+		}
+		add = append(add, addField{strings.ToLower(k), k + ": " + v + "\r\n"})
+	}
+
+	var nhdr []byte
+	for _, f := range fields {
+		key := headerFieldKey(f)
+		if matchRemove(key) {
+			continue
+		}
+		if key == "from" && r.FromDisplayName != "" {
+			_, v, _ := bytes.Cut(f, []byte(":"))
+			unfolded := strings.NewReplacer("\r\n ", " ", "\r\n\t", " ").Replace(string(v))
+			if a, err := mail.ParseAddress(strings.TrimSpace(unfolded)); err == nil {
+				a.Name = strings.TrimSpace(strings.ReplaceAll(r.FromDisplayName, "{name}", a.Name))
+				nhdr = append(nhdr, "From: "+a.String()+"\r\n"...)
+				continue
+			}
+		}
+		replaced := false
+		for _, af := range add {
+			if af.key == key {
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			nhdr = append(nhdr, f...)
+		}
+	}
+	for _, af := range add {
+		nhdr = append(nhdr, af.value...)
+	}
+	return nhdr
+}
@@ -0,0 +1,103 @@
+package store
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestOutgoingHeaderRules(t *testing.T) {
+	crlf := func(s string) string { return strings.ReplaceAll(s, "\n", "\r\n") }
+
+	hdr := crlf(`From: Mjl <mjl@mox.example>
+To: <other@remote.example>
+X-Internal-Id: 123
+X-Internal-Route:
+ folded
+Reply-To: <old@mox.example>
+Subject: test
+`)
+	r := &config.OutgoingHeaderRules{
+		Remove:          []string{"x-internal-*", "X-Mailer"},
+		FromDisplayName: "{name} (Example Corp)",
+		ReplyTo:         "Support <support@mox.example>",
+		Add:             []string{"X-Disclaimer: Confidential", "X-Note: café"},
+	}
+	got := string(outgoingHeaderRewrite([]byte(hdr), r))
+	exp := crlf(`From: "Mjl (Example Corp)" <mjl@mox.example>
+To: <other@remote.example>
+Subject: test
+Reply-To: "Support" <support@mox.example>
+X-Disclaimer: Confidential
+X-Note: =?utf-8?q?caf=C3=A9?=
+`)
+	tcompare(t, got, exp)
+
+	// Without matching rules, the header is unchanged.
+	got = string(outgoingHeaderRewrite([]byte(hdr), &config.OutgoingHeaderRules{Remove: []string{"X-Other"}}))
+	tcompare(t, got, hdr)
+
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	msgFrom := smtp.NewAddress("mjl", dns.Domain{ASCII: "mox.example"})
+	msg := hdr + "\r\nbody\r\n"
+	f, err := CreateMessageTemp(log, "headerrules-test")
+	tcheck(t, err, "create temp file")
+	defer CloseRemoveTempFile(log, f, "test message")
+	_, err = f.Write([]byte(msg))
+	tcheck(t, err, "write message")
+
+	// No rules configured.
+	_, changed, err := acc.ApplyOutgoingHeaderRules(log, msgFrom, f)
+	tcheck(t, err, "apply rules")
+	tcompare(t, changed, false)
+
+	accConf, _ := acc.Conf()
+	accConf.OutgoingHeaderRules = &config.OutgoingHeaderRules{Remove: []string{"X-Internal-*"}}
+	mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	defer func() {
+		accConf.OutgoingHeaderRules = nil
+		mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	}()
+
+	size, changed, err := acc.ApplyOutgoingHeaderRules(log, msgFrom, f)
+	tcheck(t, err, "apply rules")
+	tcompare(t, changed, true)
+	_, err = f.Seek(0, 0)
+	tcheck(t, err, "seek")
+	buf, err := io.ReadAll(f)
+	tcheck(t, err, "read message")
+	exp = crlf(`From: Mjl <mjl@mox.example>
+To: <other@remote.example>
+Reply-To: <old@mox.example>
+Subject: test
+
+body
+`)
+	tcompare(t, string(buf), exp)
+	tcompare(t, size, int64(len(exp)))
+}
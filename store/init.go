@@ -25,7 +25,7 @@ type AccountRemove struct {
 
 // AuthDB and AuthDBTypes are exported for ../backup.go.
 var AuthDB *bstore.DB
-var AuthDBTypes = []any{TLSPublicKey{}, LoginAttempt{}, LoginAttemptState{}, AccountRemove{}}
+var AuthDBTypes = []any{TLSPublicKey{}, LoginAttempt{}, LoginAttemptState{}, AccountRemove{}, Passkey{}, AuditEvent{}, ProvisioningToken{}, ProvisioningIdempotency{}, DKIMRotation{}, DKIMRotationEvent{}, SignupInvite{}, SignupRequest{}}
 
 var loginAttemptCleanerStop chan chan struct{}
 
@@ -76,6 +76,12 @@ func Init(ctx context.Context) error {
 		for {
 			err := LoginAttemptCleanup(ctx)
 			pkglog.Check(err, "cleaning up old historic login attempts")
+			err = AuditCleanup(ctx)
+			pkglog.Check(err, "cleaning up old audit events")
+			err = ProvisioningIdempotencyCleanup(ctx)
+			pkglog.Check(err, "cleaning up old provisioning idempotency keys")
+			err = SignupCleanup(ctx)
+			pkglog.Check(err, "cleaning up old signup requests and invitations")
 
 			select {
 			case c := <-loginAttemptCleanerStop:
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package store
+
+import (
+	"os"
+	"syscall"
+)
+
+// linkCount returns the number of hard links to the file of fi, and whether the
+// link count is available.
+func linkCount(fi os.FileInfo) (int64, bool) {
+	st, ok := fi.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, false
+	}
+	return int64(st.Nlink), true
+}
@@ -0,0 +1,12 @@
+package store
+
+import (
+	"os"
+)
+
+// linkCount returns the number of hard links to the file of fi, and whether the
+// link count is available. Not available on Windows, message deduplication is not
+// used.
+func linkCount(fi os.FileInfo) (int64, bool) {
+	return 0, false
+}
@@ -33,7 +33,8 @@ type LoginAttempt struct {
 	First time.Time `bstore:"nonzero,default now"`
 	Count int64     // Number of login attempts for the combination of fields below.
 
-	// Admin logins use "(admin)". If no account is known, "-" is used.
+	// Admin logins use "(admin)", or "(admin:<user>)" for admin users. If no account
+	// is known, "-" is used.
 	// AccountName has an index for efficiently removing failed login attempts at the
 	// end of the list when there are too many, and for efficiently removing all records
 	// for an account.
@@ -88,15 +89,20 @@ type LoginAttemptState struct {
 type AuthResult string
 
 const (
-	AuthSuccess           AuthResult = "ok"
-	AuthBadUser           AuthResult = "baduser"
-	AuthBadPassword       AuthResult = "badpassword"
-	AuthBadCredentials    AuthResult = "badcreds"
-	AuthBadChannelBinding AuthResult = "badchanbind"
-	AuthBadProtocol       AuthResult = "badprotocol"
-	AuthLoginDisabled     AuthResult = "logindisabled"
-	AuthError             AuthResult = "error"
-	AuthAborted           AuthResult = "aborted"
+	AuthSuccess             AuthResult = "ok"
+	AuthBadUser             AuthResult = "baduser"
+	AuthBadPassword         AuthResult = "badpassword"
+	AuthBadCredentials      AuthResult = "badcreds"
+	AuthBadChannelBinding   AuthResult = "badchanbind"
+	AuthBadProtocol         AuthResult = "badprotocol"
+	AuthLoginDisabled       AuthResult = "logindisabled"
+	AuthLoginLocked         AuthResult = "loginlocked"
+	AuthPasswordExpired     AuthResult = "passwordexpired"
+	AuthAppPasswordRequired AuthResult = "apppasswordrequired" // Account password used while two-factor authentication requires an app password.
+	AuthTOTPRequired        AuthResult = "totprequired"        // Valid password for web login, but second factor still needed.
+	AuthTwoFactorSetup      AuthResult = "twofactorsetup"      // Two-factor authentication required but not yet set up.
+	AuthError               AuthResult = "error"
+	AuthAborted             AuthResult = "aborted"
 )
 
 var writeLoginAttempt chan LoginAttempt
@@ -156,7 +162,7 @@ func startLoginAttemptWriter() {
 // LoginAttemptAdd logs a login attempt (with result), and upserts it in the
 // database and possibly cleans up old entries in the database.
 //
-// Use account name "(admin)" for admin logins.
+// Use account name "(admin)" for admin logins, or "(admin:<user>)" for admin users.
 //
 // Writes are done in a background routine, unless we are shutting down or when
 // there are many pending writes.
@@ -164,6 +170,7 @@ func LoginAttemptAdd(ctx context.Context, log mlog.Log, a LoginAttempt) {
 	metrics.AuthenticationInc(a.Protocol, a.AuthMech, string(a.Result))
 
 	a.log = log
+	loginFailureTrack(a)
 	// Send login attempt to writer. Only blocks if there are lots of login attempts.
 	writeLoginAttempt <- a
 }
@@ -204,6 +211,14 @@ func loginAttemptWrite(l ...LoginAttempt) {
 		for i := range l {
 			err := loginAttemptWriteTx(tx, &l[i])
 			l[i].log.Check(err, "adding login attempt")
+
+			// Every attempt is a separate event in the audit log, unlike login attempts that
+			// are aggregated.
+			if l[i].AuthMech != "websession" && auditEnabled() {
+				e := auditLoginEvent(l[i])
+				err := tx.Insert(&e)
+				l[i].log.Check(err, "adding audit event for login attempt")
+			}
 		}
 		return nil
 	})
@@ -9,14 +9,16 @@ import (
 
 // MsgReader provides access to a message. Reads return the "MsgPrefix" in the
 // database (typically received headers), followed by the on-disk msg file
-// contents. MsgReader is an io.Reader, io.ReaderAt and io.Closer.
+// contents. Compressed msg files, see Message.Compressed, are decompressed
+// transparently. MsgReader is an io.Reader, io.ReaderAt and io.Closer.
 type MsgReader struct {
-	prefix []byte   // First part of the message. Typically contains received headers.
-	path   string   // To on-disk message file.
-	size   int64    // Total size of message, including prefix and contents from path.
-	offset int64    // Current reading offset.
-	f      *os.File // Opened path, automatically opened after prefix has been read.
-	err    error    // If set, error to return for reads. Sets io.EOF for readers, but ReadAt ignores them.
+	prefix     []byte  // First part of the message. Typically contains received headers.
+	path       string  // To on-disk message file.
+	compressed bool    // Whether the on-disk message file is compressed.
+	size       int64   // Total size of message, including prefix and contents from path.
+	offset     int64   // Current reading offset.
+	f          msgFile // Opened path, automatically opened after prefix has been read.
+	err        error   // If set, error to return for reads. Sets io.EOF for readers, but ReadAt ignores them.
 }
 
 var errMsgClosed = errors.New("msg is closed")
@@ -25,13 +27,27 @@ var errMsgClosed = errors.New("msg is closed")
 // If initialization fails, reads will return the error.
 // Only call close on the returned MsgReader if you want to close msgFile.
 func FileMsgReader(prefix []byte, msgFile *os.File) *MsgReader {
-	mr := &MsgReader{prefix: prefix, path: msgFile.Name(), f: msgFile}
-	fi, err := msgFile.Stat()
+	return fileMsgReader(prefix, msgFile, false, -1)
+}
+
+// MessageFileReader is like FileMsgReader, but for an opened on-disk message file
+// of m, which is decompressed if m is stored compressed, also if m was read
+// before the file was compressed.
+func MessageFileReader(m Message, msgFile *os.File) *MsgReader {
+	return fileMsgReader(m.MsgPrefix, msgFile, m.Compressed, m.Size-int64(len(m.MsgPrefix)))
+}
+
+// fileMsgReader makes a MsgReader for msgFile with dataSize bytes of message data
+// if known, or -1. See openMessageFile.
+func fileMsgReader(prefix []byte, msgFile *os.File, compressed bool, dataSize int64) *MsgReader {
+	mr := &MsgReader{prefix: prefix, path: msgFile.Name(), compressed: compressed, f: msgFile}
+	f, size, err := openMessageFile(msgFile, compressed, dataSize)
 	if err != nil {
 		mr.err = err
 		return mr
 	}
-	mr.size = int64(len(prefix)) + fi.Size()
+	mr.f = f
+	mr.size = int64(len(prefix)) + size
 	return mr
 }
 
@@ -78,7 +94,13 @@ func (m *MsgReader) read(buf []byte, off int64, pread bool) (int, error) {
 				m.err = err
 				break
 			}
-			m.f = f
+			mf, _, err := openMessageFile(f, m.compressed, m.size-int64(len(m.prefix)))
+			if err != nil {
+				f.Close()
+				m.err = err
+				break
+			}
+			m.f = mf
 		}
 		n, err := m.f.ReadAt(buf[o:], off-int64(len(m.prefix)))
 		if !pread && n > 0 {
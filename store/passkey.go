@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/mjl-/bstore"
+)
+
+// Passkey is a WebAuthn public key credential for logging into the web interfaces
+// without password, or as second factor after the password.
+type Passkey struct {
+	// Raw-url-base64-encoded credential ID, as chosen by the authenticator.
+	CredentialID string
+	Created      time.Time `bstore:"nonzero,default now"`
+
+	// Descriptive name to identify the passkey, e.g. the device or password manager.
+	Name string `bstore:"nonzero"`
+
+	// Account the passkey authenticates. Empty for passkeys of the admin.
+	Account string `bstore:"index"`
+
+	// Login address for sessions, the address used when the passkey was registered.
+	// Empty for admin.
+	LoginAddress string
+
+	PublicKey []byte    `bstore:"nonzero" json:"-"` // COSE-encoded.
+	SignCount uint32    // Signature counter of authenticator, zero if not implemented.
+	LastUsed  time.Time // Zero if not used.
+}
+
+// PasskeyCredentialID returns the credential ID as stored in Passkey.
+func PasskeyCredentialID(id []byte) string {
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// PasskeyList returns the passkeys for an account, or for the admin if account is
+// empty.
+func PasskeyList(ctx context.Context, account string) ([]Passkey, error) {
+	return bstore.QueryDB[Passkey](ctx, AuthDB).FilterEqual("Account", account).SortAsc("Created").List()
+}
+
+// PasskeyGet retrieves a passkey by credential ID. If absent, bstore.ErrAbsent is
+// returned.
+func PasskeyGet(ctx context.Context, credentialID string) (Passkey, error) {
+	pk := Passkey{CredentialID: credentialID}
+	err := AuthDB.Get(ctx, &pk)
+	return pk, err
+}
+
+// PasskeyAdd adds a new passkey.
+//
+// Caller is responsible for checking the account is valid.
+func PasskeyAdd(ctx context.Context, pk *Passkey) error {
+	if err := AuthDB.Insert(ctx, pk); err == bstore.ErrUnique {
+		return fmt.Errorf("passkey already registered")
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// PasskeyUsed stores the signature counter after a successful login, and when
+// the passkey was last used.
+func PasskeyUsed(ctx context.Context, credentialID string, signCount uint32) error {
+	return AuthDB.Write(ctx, func(tx *bstore.Tx) error {
+		pk := Passkey{CredentialID: credentialID}
+		if err := tx.Get(&pk); err != nil {
+			return err
+		}
+		pk.SignCount = signCount
+		pk.LastUsed = time.Now()
+		return tx.Update(&pk)
+	})
+}
+
+// PasskeyRemove removes a passkey of an account, or of the admin if account is
+// empty.
+func PasskeyRemove(ctx context.Context, account, credentialID string) error {
+	return AuthDB.Write(ctx, func(tx *bstore.Tx) error {
+		pk := Passkey{CredentialID: credentialID}
+		if err := tx.Get(&pk); err != nil {
+			return err
+		} else if pk.Account != account {
+			return bstore.ErrAbsent
+		}
+		return tx.Delete(&pk)
+	})
+}
+
+// passkeyRemoveForAccount removes all passkeys for an account.
+func passkeyRemoveForAccount(tx *bstore.Tx, account string) error {
+	q := bstore.QueryTx[Passkey](tx)
+	q.FilterNonzero(Passkey{Account: account})
+	_, err := q.Delete()
+	return err
+}
+
+// HasPasskeys returns whether the account has passkeys, which count as second
+// factor.
+func (a *Account) HasPasskeys() (bool, error) {
+	return bstore.QueryDB[Passkey](context.TODO(), AuthDB).FilterNonzero(Passkey{Account: a.Name}).Exists()
+}
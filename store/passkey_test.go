@@ -0,0 +1,85 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestPasskey(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	has, err := acc.HasPasskeys()
+	tcheck(t, err, "has passkeys")
+	if has {
+		t.Fatalf("account has passkeys before adding")
+	}
+
+	id := PasskeyCredentialID([]byte("credential-1"))
+	pk := Passkey{CredentialID: id, Name: "phone", Account: "mjl", LoginAddress: "mjl@mox.example", PublicKey: []byte("key"), SignCount: 1}
+	err = PasskeyAdd(ctxbg, &pk)
+	tcheck(t, err, "add passkey")
+	err = PasskeyAdd(ctxbg, &Passkey{CredentialID: id, Name: "other", Account: "mjl", PublicKey: []byte("key")})
+	if err == nil {
+		t.Fatalf("duplicate passkey accepted")
+	}
+	err = PasskeyAdd(ctxbg, &Passkey{CredentialID: PasskeyCredentialID([]byte("credential-2")), Name: "admin", PublicKey: []byte("key")})
+	tcheck(t, err, "add admin passkey")
+
+	has, err = acc.HasPasskeys()
+	tcheck(t, err, "has passkeys")
+	if !has {
+		t.Fatalf("account has no passkeys after adding")
+	}
+	l, err := PasskeyList(ctxbg, "mjl")
+	tcheck(t, err, "list passkeys")
+	if len(l) != 1 || l[0].CredentialID != id {
+		t.Fatalf("got passkeys %v, expected one for account", l)
+	}
+	l, err = PasskeyList(ctxbg, "")
+	tcheck(t, err, "list admin passkeys")
+	if len(l) != 1 || l[0].Name != "admin" {
+		t.Fatalf("got passkeys %v, expected one for admin", l)
+	}
+
+	err = PasskeyUsed(ctxbg, id, 2)
+	tcheck(t, err, "passkey used")
+	pk, err = PasskeyGet(ctxbg, id)
+	tcheck(t, err, "get passkey")
+	if pk.SignCount != 2 || pk.LastUsed.IsZero() {
+		t.Fatalf("passkey not updated after use: %v", pk)
+	}
+
+	// Passkey cannot be removed through another account or the admin.
+	if err := PasskeyRemove(ctxbg, "", id); !errors.Is(err, bstore.ErrAbsent) {
+		t.Fatalf("got err %v, expected ErrAbsent", err)
+	}
+	err = PasskeyRemove(ctxbg, "mjl", id)
+	tcheck(t, err, "remove passkey")
+	if _, err := PasskeyGet(ctxbg, id); !errors.Is(err, bstore.ErrAbsent) {
+		t.Fatalf("got err %v, expected ErrAbsent after remove", err)
+	}
+}
@@ -0,0 +1,304 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log/slog"
+	"math"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+// RecoveryCode is a one-time code that can be used as second factor instead of a
+// two-factor authentication code when logging in to the web interfaces, e.g. after
+// losing the authenticator app. The password is still required. Only a hash of
+// the code is stored.
+type RecoveryCode struct {
+	ID      int64
+	Created time.Time `bstore:"default now"`
+	Hash    []byte    // SHA-256 of normalized code.
+}
+
+// Number of recovery codes generated at a time.
+const recoveryCodesCount = 10
+
+// Characters in recovery codes, without easily confused characters like 0/o and 1/l.
+const recoveryCodeChars = "abcdefghjkmnpqrstuvwxyz23456789"
+
+// Expired returns whether the password has expired, either because the admin
+// required a change or because it is older than the maximum age of the password
+// policy of the account.
+func (pw Password) Expired(accConf config.Account, now time.Time) bool {
+	if pw.MustChange {
+		return true
+	}
+	pp := accConf.PasswordPolicy
+	return pp != nil && pp.MaxAge > 0 && !pw.Changed.IsZero() && now.Sub(pw.Changed) > pp.MaxAge
+}
+
+// PasswordEntropy returns an estimate of the strength of password in bits, based
+// on its length and the kinds of characters used. Characters repeating the
+// previous character don't add strength.
+func PasswordEntropy(password string) int {
+	var lower, upper, digit, punct, other bool
+	var n int
+	var prev rune
+	for i, c := range password {
+		switch {
+		case c >= 'a' && c <= 'z':
+			lower = true
+		case c >= 'A' && c <= 'Z':
+			upper = true
+		case c >= '0' && c <= '9':
+			digit = true
+		case c < 0x80 && unicode.IsPrint(c):
+			punct = true
+		default:
+			other = true
+		}
+		if i == 0 || c != prev {
+			n++
+		}
+		prev = c
+	}
+	var pool int
+	for _, t := range []struct {
+		present bool
+		size    int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {punct, 33}, {other, 100}} {
+		if t.present {
+			pool += t.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+	return int(float64(n) * math.Log2(float64(pool)))
+}
+
+// CheckPasswordPolicy returns an error if password does not meet the requirements
+// of the password policy of the account. Only for passwords chosen by the user,
+// the minimum length of 8 characters is checked by SetPassword.
+func CheckPasswordPolicy(accConf config.Account, password string) error {
+	pp := accConf.PasswordPolicy
+	if pp == nil {
+		return nil
+	}
+	if pp.MinLength > 0 && utf8.RuneCountInString(password) < pp.MinLength {
+		return fmt.Errorf("password must be at least %d characters", pp.MinLength)
+	}
+	if e := PasswordEntropy(password); pp.MinEntropy > 0 && e < pp.MinEntropy {
+		return fmt.Errorf("password too weak, estimated strength is %d bits, at least %d bits required; use a longer password with more kinds of characters", e, pp.MinEntropy)
+	}
+	return nil
+}
+
+// Consecutive failed login attempts per account, for locking logins. Kept in
+// memory only, a restart resets the counts.
+var loginFailures = struct {
+	sync.Mutex
+	accounts map[string]loginFailure
+}{accounts: map[string]loginFailure{}}
+
+type loginFailure struct {
+	count int
+	last  time.Time
+}
+
+func lockoutPeriod(pp *config.PasswordPolicy) time.Duration {
+	if pp.LockoutPeriod > 0 {
+		return pp.LockoutPeriod
+	}
+	return 15 * time.Minute
+}
+
+// loginFailureTrack updates the count of consecutive failed login attempts for the
+// account of the login attempt. Called for all login attempts.
+func loginFailureTrack(a LoginAttempt) {
+	if a.AccountName == "" || a.AccountName == "-" || strings.HasPrefix(a.AccountName, "(admin") || a.AuthMech == "websession" {
+		return
+	}
+
+	loginFailures.Lock()
+	defer loginFailures.Unlock()
+
+	switch a.Result {
+	case AuthSuccess:
+		delete(loginFailures.accounts, a.AccountName)
+	case AuthBadPassword, AuthBadCredentials:
+		accConf, ok := mox.Conf.Account(a.AccountName)
+		pp := accConf.PasswordPolicy
+		if !ok || pp == nil || pp.LockoutFailures == 0 {
+			return
+		}
+		now := time.Now()
+		f := loginFailures.accounts[a.AccountName]
+		// Failures long ago don't count towards a new lockout.
+		if now.Sub(f.last) > lockoutPeriod(pp) {
+			f.count = 0
+		}
+		f.count++
+		f.last = now
+		loginFailures.accounts[a.AccountName] = f
+		if f.count == pp.LockoutFailures {
+			a.log.Info("locking logins for account after too many failed attempts", slog.String("account", a.AccountName), slog.Int("failures", f.count))
+		}
+	}
+}
+
+// CheckLoginLockout returns ErrLoginLocked if login attempts for the account are
+// rejected because of too many recent consecutive failed attempts, as configured
+// in the password policy of the account.
+func CheckLoginLockout(accountName string) error {
+	accConf, ok := mox.Conf.Account(accountName)
+	pp := accConf.PasswordPolicy
+	if !ok || pp == nil || pp.LockoutFailures == 0 {
+		return nil
+	}
+
+	loginFailures.Lock()
+	defer loginFailures.Unlock()
+	f := loginFailures.accounts[accountName]
+	if f.count >= pp.LockoutFailures && time.Since(f.last) < lockoutPeriod(pp) {
+		return ErrLoginLocked
+	}
+	return nil
+}
+
+// CheckPasswordLogin returns ErrPasswordExpired if the password of the account
+// has expired, or ErrAppPassword if the account password cannot be used because
+// of two-factor authentication. For login checks with the account password by
+// mechanisms that don't use OpenEmailAuth, like SCRAM.
+func (a *Account) CheckPasswordLogin() error {
+	accConf, ok := a.Conf()
+	if !ok {
+		return fmt.Errorf("cannot find config for account")
+	}
+	pw, err := bstore.QueryDB[Password](context.TODO(), a.DB).Get()
+	if err == bstore.ErrAbsent {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("looking up password: %v", err)
+	}
+	if pw.Expired(accConf, time.Now()) {
+		return ErrPasswordExpired
+	}
+	if required, err := a.appPasswordRequired(accConf); err != nil {
+		return err
+	} else if required {
+		return ErrAppPassword
+	}
+	return nil
+}
+
+// PasswordRequireChange marks the password of the account as expired. Logging in
+// with other protocols than the account web interface is only possible after
+// setting a new password. Existing sessions are removed.
+func (a *Account) PasswordRequireChange(log mlog.Log) error {
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		pw, err := bstore.QueryTx[Password](tx).Get()
+		if err == bstore.ErrAbsent {
+			return fmt.Errorf("account has no password")
+		} else if err != nil {
+			return fmt.Errorf("looking up password: %v", err)
+		}
+		pw.MustChange = true
+		if err := tx.Update(&pw); err != nil {
+			return fmt.Errorf("updating password: %v", err)
+		}
+		return sessionRemoveAll(context.TODO(), log, tx, a.Name)
+	})
+	if err == nil {
+		log.Info("password change required for account", slog.String("account", a.Name))
+	}
+	return err
+}
+
+// randomCode returns a random code of 16 characters in groups of 4, easy to type.
+// Used for recovery codes and app passwords.
+func randomCode() string {
+	var b strings.Builder
+	for j := range 16 {
+		if j > 0 && j%4 == 0 {
+			b.WriteByte('-')
+		}
+		b.WriteByte(recoveryCodeChars[uint64(mox.CryptoRandInt())%uint64(len(recoveryCodeChars))])
+	}
+	return b.String()
+}
+
+func recoveryCodeHash(code string) []byte {
+	code = strings.ToLower(strings.NewReplacer(" ", "", "-", "").Replace(code))
+	h := sha256.Sum256([]byte(code))
+	return h[:]
+}
+
+// RecoveryCodesGenerate replaces the recovery codes of the account with new
+// codes, returned in plain text. The codes cannot be retrieved later.
+func (a *Account) RecoveryCodesGenerate(log mlog.Log) ([]string, error) {
+	codes := make([]string, recoveryCodesCount)
+	for i := range codes {
+		codes[i] = randomCode()
+	}
+
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		if _, err := bstore.QueryTx[RecoveryCode](tx).Delete(); err != nil {
+			return fmt.Errorf("removing existing recovery codes: %v", err)
+		}
+		for _, code := range codes {
+			rc := RecoveryCode{Hash: recoveryCodeHash(code)}
+			if err := tx.Insert(&rc); err != nil {
+				return fmt.Errorf("inserting recovery code: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("new recovery codes generated for account", slog.String("account", a.Name))
+	return codes, nil
+}
+
+// RecoveryCodeUse checks code against the recovery codes of the account, for use
+// as second factor instead of a two-factor authentication code, after the password
+// was verified. If it matches, the code is removed. ErrUnknownCredentials is
+// returned if the code does not match.
+func (a *Account) RecoveryCodeUse(log mlog.Log, code string) error {
+	h := recoveryCodeHash(code)
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		var rc RecoveryCode
+		err := bstore.QueryTx[RecoveryCode](tx).ForEach(func(xrc RecoveryCode) error {
+			if subtle.ConstantTimeCompare(xrc.Hash, h) == 1 {
+				rc = xrc
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("listing recovery codes: %v", err)
+		}
+		if rc.ID == 0 {
+			return ErrUnknownCredentials
+		}
+		if err := tx.Delete(&rc); err != nil {
+			return fmt.Errorf("removing used recovery code: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Info("recovery code used for account", slog.String("account", a.Name))
+	return nil
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	tcompare(t, PasswordEntropy(""), 0)
+	if PasswordEntropy("aaaaaaaa") >= PasswordEntropy("abcdefgh") {
+		t.Fatalf("repeated characters should be weaker")
+	}
+	if PasswordEntropy("abcdefgh") >= PasswordEntropy("aBcD3f#h") {
+		t.Fatalf("more kinds of characters should be stronger")
+	}
+
+	accConf, _ := acc.Conf()
+	tcheck(t, CheckPasswordPolicy(accConf, "short"), "no policy")
+	accConf.PasswordPolicy = &config.PasswordPolicy{MinLength: 12, MinEntropy: 60, MaxAge: time.Hour, LockoutFailures: 2}
+	mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	defer func() {
+		accConf.PasswordPolicy = nil
+		mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	}()
+	if err := CheckPasswordPolicy(accConf, "Short1!"); err == nil {
+		t.Fatalf("short password accepted")
+	}
+	if err := CheckPasswordPolicy(accConf, "aaaaaaaaaaaaaaaa"); err == nil {
+		t.Fatalf("weak password accepted")
+	}
+	tcheck(t, CheckPasswordPolicy(accConf, "Tr0ub4dor&3-horse"), "strong password")
+
+	err = acc.SetPassword(log, "testtest")
+	tcheck(t, err, "set password")
+	tcheck(t, acc.CheckPasswordLogin(), "check password expired")
+
+	pw := Password{Changed: time.Now().Add(-2 * time.Hour)}
+	tcompare(t, pw.Expired(accConf, time.Now()), true)
+	pw.Changed = time.Time{}
+	tcompare(t, pw.Expired(accConf, time.Now()), false)
+
+	// Admin requires password change.
+	err = acc.PasswordRequireChange(log)
+	tcheck(t, err, "require password change")
+	if err := acc.CheckPasswordLogin(); !errors.Is(err, ErrPasswordExpired) {
+		t.Fatalf("got err %v, expected ErrPasswordExpired", err)
+	}
+	if _, _, err := OpenEmailAuth(log, "mjl@mox.example", "testtest", "", nil, true); !errors.Is(err, ErrPasswordExpired) {
+		t.Fatalf("got err %v, expected ErrPasswordExpired", err)
+	}
+	err = acc.SetPassword(log, "testtest")
+	tcheck(t, err, "set password")
+	tcheck(t, acc.CheckPasswordLogin(), "check password expired after change")
+
+	// Lockout after consecutive failures, reset by success.
+	failure := LoginAttempt{AccountName: "mjl", Result: AuthBadPassword, log: log}
+	loginFailureTrack(failure)
+	tcheck(t, CheckLoginLockout("mjl"), "lockout after single failure")
+	loginFailureTrack(LoginAttempt{AccountName: "mjl", Result: AuthSuccess, log: log})
+	loginFailureTrack(failure)
+	tcheck(t, CheckLoginLockout("mjl"), "lockout after success")
+	loginFailureTrack(failure)
+	if err := CheckLoginLockout("mjl"); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("got err %v, expected ErrLoginLocked", err)
+	}
+	if _, _, err := OpenEmailAuth(log, "mjl@mox.example", "testtest", "", nil, true); !errors.Is(err, ErrLoginLocked) {
+		t.Fatalf("got err %v, expected ErrLoginLocked", err)
+	}
+
+	// Recovery codes can be used once, and don't expire the password.
+	codes, err := acc.RecoveryCodesGenerate(log)
+	tcheck(t, err, "generate recovery codes")
+	tcompare(t, len(codes), recoveryCodesCount)
+	err = acc.RecoveryCodeUse(log, "bogus")
+	if !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials", err)
+	}
+	err = acc.RecoveryCodeUse(log, codes[0])
+	tcheck(t, err, "use recovery code")
+	err = acc.RecoveryCodeUse(log, codes[0])
+	if !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for reused code", err)
+	}
+	tcheck(t, acc.CheckPasswordLogin(), "check password after recovery code")
+
+	loginFailureTrack(LoginAttempt{AccountName: "mjl", Result: AuthSuccess, log: log})
+	tcheck(t, CheckLoginLockout("mjl"), "lockout after success")
+}
+
+// testAuthenticator accepts logins with password "external", for account mjl.
+type testAuthenticator struct{}
+
+func (testAuthenticator) Authenticate(ctx context.Context, log mlog.Log, login, password string) (string, error) {
+	if password != "external" {
+		return "", ErrUnknownCredentials
+	}
+	switch login {
+	case "mjl@mox.example", "mjl":
+		return "mjl", nil
+	case "other":
+		return "other", nil
+	}
+	return "", ErrUnknownCredentials
+}
+
+func TestExternalAuth(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+	err = acc.SetPassword(log, "testtest")
+	tcheck(t, err, "set password")
+
+	ExternalAuth = testAuthenticator{}
+	defer func() {
+		ExternalAuth = nil
+	}()
+
+	openAuth := func(login, password string) error {
+		t.Helper()
+		a, accName, err := OpenEmailAuth(log, login, password, "imap", nil, false)
+		if err == nil {
+			tcompare(t, accName, "mjl")
+			err = a.Close()
+			tcheck(t, err, "close account")
+		}
+		return err
+	}
+	tcheck(t, openAuth("mjl@mox.example", "testtest"), "login with account password")
+	tcheck(t, openAuth("mjl@mox.example", "external"), "login with external password")
+	tcheck(t, openAuth("mjl", "external"), "login with external user name")
+	for _, login := range []string{"mjl@mox.example", "mjl", "other", "bogus@mox.example"} {
+		if err := openAuth(login, "bogus"); !errors.Is(err, ErrUnknownCredentials) {
+			t.Fatalf("got err %v for %s, expected ErrUnknownCredentials", err, login)
+		}
+	}
+	// Account for external login doesn't exist.
+	if err := openAuth("other", "external"); err == nil {
+		t.Fatalf("login for unknown account accepted")
+	}
+}
@@ -0,0 +1,115 @@
+package store
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/mjl-/mox/wkd"
+)
+
+// ParsePGPKey parses an armored OpenPGP public or private key, for storing as
+// PGPKey. The text must contain exactly one key. For private keys, the private key
+// is kept as given, typically encrypted with a passphrase. Keys with algorithms
+// that cannot be used, such as Ed25519/Curve25519, result in an error wrapping
+// wkd.ErrKeyUnsupported.
+func ParsePGPKey(armored string) (PGPKey, error) {
+	l, err := pgpReadArmoredKeyRing(armored)
+	if err != nil {
+		return PGPKey{}, fmt.Errorf("reading key: %w", err)
+	} else if len(l) != 1 {
+		return PGPKey{}, fmt.Errorf("got %d keys, need exactly 1", len(l))
+	}
+	e := l[0]
+
+	var pub bytes.Buffer
+	w, err := armor.Encode(&pub, openpgp.PublicKeyType, nil)
+	if err == nil {
+		err = e.Serialize(w)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	if err != nil {
+		return PGPKey{}, fmt.Errorf("writing public key: %v", err)
+	}
+
+	fp := strings.ToUpper(hex.EncodeToString(e.PrimaryKey.Fingerprint[:]))
+	k := PGPKey{
+		Fingerprint: fp,
+		KeyID:       fp[len(fp)-16:],
+		PublicKey:   pub.String() + "\n",
+	}
+	for _, a := range wkd.EntityAddresses(e) {
+		k.Addresses = append(k.Addresses, a.String())
+	}
+	if e.PrivateKey != nil {
+		k.PrivateKey = armored
+	}
+	return k, nil
+}
+
+// Entity returns the parsed key, including the private key if present. A private
+// key is decrypted with passphrase if it is encrypted.
+func (k PGPKey) Entity(passphrase string) (*openpgp.Entity, error) {
+	s := k.PublicKey
+	if k.PrivateKey != "" {
+		s = k.PrivateKey
+	}
+	l, err := pgpReadArmoredKeyRing(s)
+	if err != nil {
+		return nil, fmt.Errorf("reading key: %w", err)
+	} else if len(l) != 1 {
+		return nil, fmt.Errorf("got %d keys, need exactly 1", len(l))
+	}
+	e := l[0]
+	if e.PrivateKey == nil {
+		return e, nil
+	}
+	keys := []*packet.PrivateKey{e.PrivateKey}
+	for _, sk := range e.Subkeys {
+		if sk.PrivateKey != nil {
+			keys = append(keys, sk.PrivateKey)
+		}
+	}
+	for _, pk := range keys {
+		if !pk.Encrypted {
+			continue
+		}
+		if passphrase == "" {
+			return nil, ErrPGPPassphrase
+		}
+		if err := pk.Decrypt([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrPGPPassphrase, err)
+		}
+	}
+	return e, nil
+}
+
+// pgpReadArmoredKeyRing reads the keys in armored, returning an error wrapping
+// wkd.ErrKeyUnsupported instead of the error from openpgp for keys with algorithms
+// it does not support.
+func pgpReadArmoredKeyRing(armored string) (openpgp.EntityList, error) {
+	l, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err == nil {
+		return l, nil
+	}
+	if block, xerr := armor.Decode(strings.NewReader(armored)); xerr == nil {
+		if buf, xerr := io.ReadAll(block.Body); xerr == nil {
+			if xerr := wkd.CheckKeyAlgorithms(buf); xerr != nil {
+				return nil, xerr
+			}
+		}
+	}
+	return nil, err
+}
+
+// ErrPGPPassphrase is returned by PGPKey.Entity for a missing or bad passphrase.
+var ErrPGPPassphrase = errors.New("missing or bad passphrase for private key")
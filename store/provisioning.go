@@ -0,0 +1,110 @@
+package store
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mjl-/bstore"
+)
+
+// ProvisioningToken is a bearer token for the provisioning API. Only a hash of
+// the token is stored.
+type ProvisioningToken struct {
+	ID      int64
+	Created time.Time `bstore:"nonzero,default now"`
+
+	// Descriptive name, e.g. of the hosting panel using the token.
+	Name string `bstore:"nonzero,unique"`
+
+	TokenHash string `bstore:"nonzero,unique" json:"-"` // Hex SHA-256 of the token.
+	LastUsed  time.Time
+}
+
+// ProvisioningIdempotency is the stored response to a provisioning API request
+// with an idempotency key. A repeated request with the same key gets the same
+// response, without making changes again. Removed after 24 hours.
+type ProvisioningIdempotency struct {
+	Key        string    // Token name, newline, idempotency key.
+	Created    time.Time `bstore:"nonzero,default now,index"`
+	Request    string    // Method, path and SHA-256 of body, for detecting reuse of a key for another request.
+	StatusCode int
+	Response   []byte
+}
+
+// ErrProvisioningToken is returned for unknown provisioning tokens.
+var ErrProvisioningToken = errors.New("unknown provisioning token")
+
+func provisioningTokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// ProvisioningTokenAdd adds a new provisioning token with name, returning the
+// token. The token cannot be retrieved later.
+func ProvisioningTokenAdd(ctx context.Context, name string) (token string, rerr error) {
+	buf := make([]byte, 24)
+	cryptorand.Read(buf)
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	pt := ProvisioningToken{Name: name, TokenHash: provisioningTokenHash(token)}
+	if err := AuthDB.Insert(ctx, &pt); err != nil {
+		if errors.Is(err, bstore.ErrUnique) {
+			return "", fmt.Errorf("token with name %q already exists", name)
+		}
+		return "", err
+	}
+	return token, nil
+}
+
+// ProvisioningTokenList returns all provisioning tokens, without the token
+// itself.
+func ProvisioningTokenList(ctx context.Context) ([]ProvisioningToken, error) {
+	return bstore.QueryDB[ProvisioningToken](ctx, AuthDB).SortAsc("Name").List()
+}
+
+// ProvisioningTokenRemove removes the provisioning token with name.
+func ProvisioningTokenRemove(ctx context.Context, name string) error {
+	q := bstore.QueryDB[ProvisioningToken](ctx, AuthDB)
+	q.FilterNonzero(ProvisioningToken{Name: name})
+	n, err := q.Delete()
+	if err == nil && n == 0 {
+		return fmt.Errorf("%w: no token with name %q", ErrProvisioningToken, name)
+	}
+	return err
+}
+
+// ProvisioningTokenCheck returns the provisioning token matching token, updating
+// its LastUsed field.
+func ProvisioningTokenCheck(ctx context.Context, token string) (ProvisioningToken, error) {
+	hash := provisioningTokenHash(token)
+	var pt ProvisioningToken
+	err := AuthDB.Write(ctx, func(tx *bstore.Tx) error {
+		q := bstore.QueryTx[ProvisioningToken](tx)
+		q.FilterNonzero(ProvisioningToken{TokenHash: hash})
+		var err error
+		pt, err = q.Get()
+		if err == bstore.ErrAbsent || err == nil && subtle.ConstantTimeCompare([]byte(pt.TokenHash), []byte(hash)) != 1 {
+			return ErrProvisioningToken
+		} else if err != nil {
+			return err
+		}
+		pt.LastUsed = time.Now()
+		return tx.Update(&pt)
+	})
+	return pt, err
+}
+
+// ProvisioningIdempotencyCleanup removes stored responses for idempotency keys
+// older than 24 hours.
+func ProvisioningIdempotencyCleanup(ctx context.Context) error {
+	q := bstore.QueryDB[ProvisioningIdempotency](ctx, AuthDB)
+	q.FilterLess("Created", time.Now().Add(-24*time.Hour))
+	_, err := q.Delete()
+	return err
+}
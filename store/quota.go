@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mox-"
+)
+
+// QuotaStatus is the storage use of an account relative to its quota.
+type QuotaStatus struct {
+	Account     string
+	MessageSize int64 // Total size of messages.
+	Quota       int64 // Maximum total message size, 0 if no limit.
+	SoftLimit   int64 // 0 if no soft limit.
+	Percent     int   // Of Quota, 0 if no limit.
+
+	// End of the grace period while over the soft limit. Zero if not over the soft
+	// limit.
+	GraceEnd time.Time
+}
+
+// QuotaSoftLimit returns the soft limit for the total message size of the
+// account, or 0 if there is no soft limit.
+func (a *Account) QuotaSoftLimit() int64 {
+	pct := mox.Conf.Static.QuotaSoftPercent
+	if pct <= 0 {
+		return 0
+	}
+	return a.QuotaMessageSize() * int64(pct) / 100
+}
+
+// QuotaGracePeriod returns the period an account can be over its soft limit.
+func QuotaGracePeriod() time.Duration {
+	if d := mox.Conf.Static.QuotaGracePeriod; d > 0 {
+		return d
+	}
+	return 7 * 24 * time.Hour
+}
+
+// QuotaWarnPercentages returns the sorted percentages of the quota at which
+// warnings are given.
+func QuotaWarnPercentages() []int {
+	l := mox.Conf.Static.QuotaWarnPercentages
+	if len(l) == 0 {
+		return []int{80, 90, 95}
+	}
+	return slices.Sorted(slices.Values(l))
+}
+
+// quotaCheck returns an error with ErrOverQuota if size bytes cannot be added to
+// the account with disk usage du, because it would exceed the quota, or because
+// the grace period for the soft limit has ended. The limit that would be exceeded
+// is returned.
+func (a *Account) quotaCheck(du DiskUsage, size int64) (maxSize int64, rerr error) {
+	maxSize = a.QuotaMessageSize()
+	if maxSize <= 0 {
+		return 0, nil
+	}
+	if size > maxSize-du.MessageSize {
+		return maxSize, fmt.Errorf("%w: max size %d bytes", ErrOverQuota, maxSize)
+	}
+	soft := a.QuotaSoftLimit()
+	if soft > 0 && size > soft-du.MessageSize && !du.SoftLimitExceeded.IsZero() && time.Since(du.SoftLimitExceeded) > QuotaGracePeriod() {
+		return soft, fmt.Errorf("%w: grace period for soft limit of %d bytes has ended", ErrOverQuota, soft)
+	}
+	return maxSize, nil
+}
+
+// quotaTrack starts or clears the grace period for the soft limit after a change
+// in disk usage. The caller must store du.
+func (a *Account) quotaTrack(du *DiskUsage) {
+	soft := a.QuotaSoftLimit()
+	if soft > 0 && du.MessageSize > soft {
+		if du.SoftLimitExceeded.IsZero() {
+			du.SoftLimitExceeded = time.Now()
+		}
+	} else {
+		du.SoftLimitExceeded = time.Time{}
+	}
+}
+
+// quotaStatus returns the quota status for the account with disk usage du.
+func (a *Account) quotaStatus(du DiskUsage) QuotaStatus {
+	qs := QuotaStatus{
+		Account:     a.Name,
+		MessageSize: du.MessageSize,
+		Quota:       a.QuotaMessageSize(),
+		SoftLimit:   a.QuotaSoftLimit(),
+	}
+	if qs.Quota > 0 {
+		qs.Percent = int(du.MessageSize * 100 / qs.Quota)
+	}
+	if qs.SoftLimit > 0 && du.MessageSize > qs.SoftLimit && !du.SoftLimitExceeded.IsZero() {
+		qs.GraceEnd = du.SoftLimitExceeded.Add(QuotaGracePeriod())
+	}
+	return qs
+}
+
+// QuotaStatus returns the current storage use of the account relative to its
+// quota.
+func (a *Account) QuotaStatus(tx *bstore.Tx) (QuotaStatus, error) {
+	du := DiskUsage{ID: 1}
+	if err := tx.Get(&du); err != nil {
+		return QuotaStatus{}, fmt.Errorf("get disk usage: %v", err)
+	}
+	return a.quotaStatus(du), nil
+}
+
+// Warning returns a message for users if the account is near its quota or over
+// its soft limit, or an empty string otherwise.
+func (qs QuotaStatus) Warning() string {
+	if !qs.GraceEnd.IsZero() {
+		if time.Now().After(qs.GraceEnd) {
+			return fmt.Sprintf("Storage is over the soft limit (%d%% of quota used), new messages are rejected until messages are removed.", qs.Percent)
+		}
+		return fmt.Sprintf("Storage is over the soft limit (%d%% of quota used), new messages will be rejected after %s unless messages are removed.", qs.Percent, qs.GraceEnd.Format("2006-01-02 15:04 MST"))
+	}
+	if qs.Quota > 0 && qs.Percent >= QuotaWarnPercentages()[0] {
+		return fmt.Sprintf("Storage is at %d%% of quota, remove messages to make room for new messages.", qs.Percent)
+	}
+	return ""
+}
+
+// QuotaWarnCheck updates the highest warning percentage reached by the account,
+// and returns the percentage if it is higher than for the previous warning, for
+// which a warning message should be delivered. Returns 0 if no warning should be
+// delivered.
+func (a *Account) QuotaWarnCheck(ctx context.Context) (qs QuotaStatus, warn int, rerr error) {
+	if a.QuotaMessageSize() <= 0 {
+		return QuotaStatus{}, 0, nil
+	}
+	rerr = a.DB.Write(ctx, func(tx *bstore.Tx) error {
+		du := DiskUsage{ID: 1}
+		if err := tx.Get(&du); err != nil {
+			return fmt.Errorf("get disk usage: %v", err)
+		}
+		qs = a.quotaStatus(du)
+		var reached int
+		for _, pct := range QuotaWarnPercentages() {
+			if qs.Percent >= pct {
+				reached = pct
+			}
+		}
+		if reached == du.QuotaWarned {
+			return nil
+		}
+		if reached > du.QuotaWarned {
+			warn = reached
+		}
+		du.QuotaWarned = reached
+		return tx.Update(&du)
+	})
+	return
+}
@@ -0,0 +1,133 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+)
+
+// ScreeningSender is an entry in the allow or block list of first-contact
+// screening, as stored by earlier versions. Entries are moved to the sender
+// lists when an account is opened, see SenderListEntry.
+//
+// Deprecated: Use SenderListEntry.
+type ScreeningSender struct {
+	ID      int64
+	Created time.Time `bstore:"default now"`
+	Address string    `bstore:"nonzero,unique"` // Lower case address with unicode domain.
+	Blocked bool      // If set, messages from the sender are rejected. Otherwise the sender is allowed.
+}
+
+// upgradeScreeningSenders moves the entries of the screening allow and block
+// lists to the sender lists, with action allow or reject. Existing sender list
+// entries for the same address are kept. Blocked senders were only rejected with
+// screening enabled, they are now rejected regardless.
+func (a *Account) upgradeScreeningSenders(log mlog.Log) error {
+	var n int
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		l, err := bstore.QueryTx[ScreeningSender](tx).List()
+		if err != nil {
+			return fmt.Errorf("listing screening senders: %v", err)
+		}
+		for _, ss := range l {
+			exists, err := bstore.QueryTx[SenderListEntry](tx).FilterNonzero(SenderListEntry{Pattern: ss.Address}).Exists()
+			if err != nil {
+				return fmt.Errorf("looking up sender list entry: %v", err)
+			}
+			if !exists {
+				e := SenderListEntry{Created: ss.Created, Pattern: ss.Address, Action: SenderListAllow}
+				if ss.Blocked {
+					e.Action = SenderListReject
+				}
+				if err := tx.Insert(&e); err != nil {
+					return fmt.Errorf("inserting sender list entry: %v", err)
+				}
+				n++
+			}
+			if err := tx.Delete(&ss); err != nil {
+				return fmt.Errorf("removing screening sender: %v", err)
+			}
+		}
+
+		up := Upgrade{ID: 1}
+		if err := tx.Get(&up); err != nil {
+			return fmt.Errorf("get upgrade record: %v", err)
+		}
+		up.ScreeningSenders = true
+		if err := tx.Update(&up); err != nil {
+			return fmt.Errorf("marking upgrade done: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Debug("upgrade: screening senders moved to sender lists", slog.String("account", a.Name), slog.Int("added", n))
+	return nil
+}
+
+// ScreeningKnown returns whether the sender of incoming message m is known for
+// the purpose of first-contact screening: The message From address is verified,
+// and it is allowed in the sender lists, or the account has sent a message to
+// the address, or has received a message from the address before, outside the
+// screening mailbox.
+func (a *Account) ScreeningKnown(tx *bstore.Tx, conf config.Screening, m Message) (bool, error) {
+	if !m.MsgFromValidated || SenderAddress(m.MsgFromLocalpart, m.MsgFromDomain) == "" {
+		return false, nil
+	}
+
+	if e, err := SenderListMatch(tx, m.MsgFromLocalpart, m.MsgFromDomain); err != nil {
+		return false, err
+	} else if e != nil && e.Action == SenderListAllow {
+		return true, nil
+	}
+
+	// We have sent a message to the sender.
+	exists, err := bstore.QueryTx[Recipient](tx).FilterNonzero(Recipient{Localpart: m.MsgFromLocalpart.String(), Domain: m.MsgFromDomain}).Exists()
+	if err != nil {
+		return false, fmt.Errorf("looking up earlier recipients: %v", err)
+	} else if exists {
+		return true, nil
+	}
+
+	// We have received a message from the sender before, outside the screening mailbox.
+	var screeningMailboxID int64
+	if mb, err := a.MailboxFind(tx, conf.MailboxName()); err != nil {
+		return false, fmt.Errorf("looking up screening mailbox: %v", err)
+	} else if mb != nil {
+		screeningMailboxID = mb.ID
+	}
+	q := bstore.QueryTx[Message](tx)
+	q.FilterNonzero(Message{MsgFromLocalpart: m.MsgFromLocalpart, MsgFromDomain: m.MsgFromDomain, MsgFromValidated: true})
+	q.FilterEqual("Expunged", false)
+	q.FilterEqual("Junk", false)
+	q.FilterEqual("IsReject", false)
+	q.FilterNotEqual("MailboxID", screeningMailboxID)
+	exists, err = q.Exists()
+	if err != nil {
+		return false, fmt.Errorf("looking up earlier messages from sender: %v", err)
+	}
+	return exists, nil
+}
+
+// ScreeningApproveMove adds the sender of m to the allow list if screening is
+// enabled and m is moved from the screening mailbox to a mailbox other than
+// Junk or Trash. Called for each message moved through IMAP, webmail or the
+// webapi.
+func ScreeningApproveMove(tx *bstore.Tx, mbSrc, mbDst Mailbox, m Message, conf config.Account) error {
+	if conf.Screening == nil || mbSrc.Name != conf.Screening.MailboxName() || mbDst.Junk || mbDst.Trash {
+		return nil
+	}
+	addr := SenderAddress(m.MsgFromLocalpart, m.MsgFromDomain)
+	if addr == "" {
+		return nil
+	}
+	_, err := senderListSave(tx, addr, SenderListAllow)
+	return err
+}
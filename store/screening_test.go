@@ -0,0 +1,163 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestScreening(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	conf := config.Account{Screening: &config.Screening{}}
+	m := Message{MsgFromLocalpart: "Remote", MsgFromDomain: "example.org", MsgFromValidated: true}
+
+	known := func(m Message, exp bool) {
+		t.Helper()
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			known, err := acc.ScreeningKnown(tx, *conf.Screening, m)
+			tcheck(t, err, "screening known")
+			tcompare(t, known, exp)
+			return nil
+		})
+		tcheck(t, err, "read")
+	}
+
+	known(m, false)
+
+	// Moving out of the screening mailbox to Junk doesn't approve, moving to Inbox does.
+	var screening, inbox, junk Mailbox
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		screening, _, _, _, err = acc.MailboxCreate(tx, "Screening", SpecialUse{})
+		tcheck(t, err, "create screening mailbox")
+		mb, err := acc.MailboxFind(tx, "Inbox")
+		tcheck(t, err, "find inbox")
+		inbox = *mb
+		mb, err = acc.MailboxFind(tx, "Junk")
+		tcheck(t, err, "find junk")
+		junk = *mb
+		err = ScreeningApproveMove(tx, screening, junk, m, conf)
+		tcheck(t, err, "move to junk")
+		err = ScreeningApproveMove(tx, inbox, screening, m, conf)
+		tcheck(t, err, "move to screening")
+		return nil
+	})
+	tcheck(t, err, "write")
+	known(m, false)
+
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		return ScreeningApproveMove(tx, screening, inbox, m, conf)
+	})
+	tcheck(t, err, "move to inbox")
+	known(m, true)
+
+	l, err := bstore.QueryDB[SenderListEntry](ctxbg, acc.DB).List()
+	tcheck(t, err, "list")
+	tcompare(t, len(l), 1)
+	tcompare(t, l[0].Pattern, "remote@example.org")
+	tcompare(t, l[0].Action, SenderListAllow)
+
+	// Unvalidated From address is not trusted.
+	unvalidated := m
+	unvalidated.MsgFromValidated = false
+	known(unvalidated, false)
+
+	err = acc.SenderListRemove(log, l[0].ID)
+	tcheck(t, err, "remove")
+	known(m, false)
+
+	// Having sent a message to the address makes it known. Rolled back to keep the
+	// account consistent.
+	errRollback := errors.New("rollback")
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		om := Message{MailboxID: inbox.ID, MailboxOrigID: inbox.ID, UID: 1, ModSeq: 1, CreateSeq: 1, Received: time.Now()}
+		err := tx.Insert(&om)
+		tcheck(t, err, "insert message")
+		err = tx.Insert(&Recipient{MessageID: om.ID, Localpart: "Remote", Domain: "example.org", OrgDomain: "example.org", Sent: time.Now()})
+		tcheck(t, err, "insert recipient")
+		known, err := acc.ScreeningKnown(tx, *conf.Screening, m)
+		tcheck(t, err, "screening known")
+		tcompare(t, known, true)
+		return errRollback
+	})
+	if err != errRollback {
+		t.Fatalf("got err %v, expected rollback", err)
+	}
+}
+
+// Screening allow/block lists from earlier versions are moved to the sender lists.
+func TestScreeningSendersUpgrade(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	_, err = acc.SenderListSave(log, "other@example.org", SenderListAllow)
+	tcheck(t, err, "save sender list entry")
+
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		for _, ss := range []ScreeningSender{
+			{Address: "allowed@example.org"},
+			{Address: "blocked@example.org", Blocked: true},
+			{Address: "other@example.org", Blocked: true}, // Existing entry is kept.
+		} {
+			err := tx.Insert(&ss)
+			tcheck(t, err, "insert screening sender")
+		}
+		up := Upgrade{ID: 1}
+		err := tx.Get(&up)
+		tcheck(t, err, "get upgrade")
+		up.ScreeningSenders = false
+		return tx.Update(&up)
+	})
+	tcheck(t, err, "insert screening senders")
+
+	err = acc.upgradeScreeningSenders(log)
+	tcheck(t, err, "upgrade screening senders")
+
+	l, err := bstore.QueryDB[SenderListEntry](ctxbg, acc.DB).SortAsc("Pattern").List()
+	tcheck(t, err, "list sender list entries")
+	tcompare(t, len(l), 3)
+	tcompare(t, []string{l[0].Pattern, l[1].Pattern, l[2].Pattern}, []string{"allowed@example.org", "blocked@example.org", "other@example.org"})
+	tcompare(t, []SenderListAction{l[0].Action, l[1].Action, l[2].Action}, []SenderListAction{SenderListAllow, SenderListReject, SenderListAllow})
+
+	n, err := bstore.QueryDB[ScreeningSender](ctxbg, acc.DB).Count()
+	tcheck(t, err, "count screening senders")
+	tcompare(t, n, 0)
+	up, err := bstore.QueryDB[Upgrade](ctxbg, acc.DB).Get()
+	tcheck(t, err, "get upgrade")
+	tcompare(t, up.ScreeningSenders, true)
+}
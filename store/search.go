@@ -55,7 +55,8 @@ func PrepareWordSearch(words, notWords []string) WordSearch {
 
 // MatchPart returns whether the part/mail message p matches the search.
 // The search terms are matched against content-transfer-decoded and
-// charset-decoded bodies and optionally headers.
+// charset-decoded bodies and optionally headers, and text extracted from
+// attachments, see TextExtractor.
 // HTML parts are currently treated as regular text, without parsing HTML.
 func (ws WordSearch) MatchPart(log mlog.Log, p *message.Part, headerToo bool) (bool, error) {
 	seen := map[int]bool{}
@@ -83,8 +84,12 @@ func (ws WordSearch) matchPart(log mlog.Log, p *message.Part, headerToo bool, se
 
 	if len(p.Parts) == 0 {
 		if p.MediaType != "TEXT" {
-			// todo: for other types we could try to find a library for parsing and search in there too.
-			return false, nil
+			// For attachments like PDFs, we search the extracted text, if any.
+			text, ok := extractPartText(log, p)
+			if !ok {
+				return false, nil
+			}
+			return ws.searchReader(log, strings.NewReader(text), seen)
 		}
 		tp := p.ReaderUTF8OrBinary()
 		// todo: for html and perhaps other types, we could try to parse as text and filter on the text.
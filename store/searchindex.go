@@ -0,0 +1,493 @@
+package store
+
+// Full-text search index.
+//
+// Searching for words in messages involves reading and decoding the message
+// files, which is slow for accounts with many messages. We keep an index of the
+// words in each message: the lower-cased runs of letters and digits in the
+// message headers, text parts, attachment filenames, text extracted from
+// attachments, and addresses. Search words
+// are matched as substrings. A search word can only be present in a message if
+// each run of letters and digits in the search word is a substring of a word in
+// the message. The index gives the candidate messages, which must still be
+// verified by reading the message, e.g. with WordSearch.MatchPart.
+//
+// With SearchIndexHashed in mox.conf, the index stores salted hashes of the
+// words, see SearchWordHash, so the index doesn't hold message text as is. The
+// salt is stored in the account database too, and given to webmail clients, so
+// with access to the database, hashes of likely words can be computed and
+// compared against the index. Substrings cannot be looked up in a hashed index,
+// only whole words. The webmail client hashes the words it searches for, and the
+// server uses the index as the result, without reading messages.
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+)
+
+// SearchWord is a word in the vocabulary of the full-text search index. Words are
+// not removed when the last message containing them is removed.
+type SearchWord struct {
+	Word string
+}
+
+// SearchWordMessage is an entry in the full-text search index, indicating a word
+// occurs in a message.
+type SearchWordMessage struct {
+	ID        int64
+	Word      string `bstore:"nonzero,index Word+MessageID"`
+	MessageID int64  `bstore:"nonzero,ref Message"` // Ref gives it its own index, for fast removal.
+}
+
+const (
+	// Words longer than searchWordMax characters are stored as overlapping parts
+	// of searchWordMax characters, each starting searchWordMax/2 characters after
+	// the previous. So search words of up to searchWordMax/2 characters can be
+	// looked up. E.g. signatures in headers result in long words.
+	searchWordMax = 64
+
+	// Search words with fewer characters match too many words in the vocabulary to
+	// be useful.
+	searchWordMin = 3
+)
+
+// ErrSearchIndexIncomplete is returned for searches with hashed words while the
+// search index of an account is being built.
+var ErrSearchIndexIncomplete = errors.New("search index is being built, try again later")
+
+// SearchWordHash returns the hash of a word, as stored in a hashed search index:
+// the hex-encoded first 16 bytes of the HMAC-SHA256 of the word, keyed with the
+// salt of the account. Clients compute the same hashes for searching.
+func SearchWordHash(salt []byte, word string) string {
+	h := hmac.New(sha256.New, salt)
+	h.Write([]byte(word))
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+func searchIndexSaltNew() []byte {
+	salt := make([]byte, 16)
+	cryptorand.Read(salt)
+	return salt
+}
+
+// searchIndexReset removes all words from the search index, and marks it as
+// incomplete with hashing as configured, for a rebuild. The salt is kept, so
+// saved searches with hashed words keep working.
+func searchIndexReset(tx *bstore.Tx, up *Upgrade, hashed bool) error {
+	if _, err := bstore.QueryTx[SearchWordMessage](tx).Delete(); err != nil {
+		return fmt.Errorf("removing words of messages from search index: %w", err)
+	}
+	if _, err := bstore.QueryTx[SearchWord](tx).Delete(); err != nil {
+		return fmt.Errorf("removing search vocabulary: %w", err)
+	}
+	up.SearchIndex = false
+	up.SearchIndexHashed = hashed
+	if hashed && len(up.SearchIndexSalt) == 0 {
+		up.SearchIndexSalt = searchIndexSaltNew()
+	}
+	return tx.Update(up)
+}
+
+// SearchIndexSalt returns the salt for hashing search words, for accounts with a
+// hashed search index. Otherwise nil is returned.
+func SearchIndexSalt(tx *bstore.Tx) ([]byte, error) {
+	up := Upgrade{ID: 1}
+	if err := tx.Get(&up); err != nil {
+		return nil, fmt.Errorf("get upgrade state: %w", err)
+	}
+	if !up.SearchIndexHashed {
+		return nil, nil
+	}
+	return up.SearchIndexSalt, nil
+}
+
+// Number of messages added to the search index per database transaction during
+// the account upgrade.
+var searchIndexBatchSize = 100
+
+// searchWords calls fn for each lower-cased word in r, a run of letters and
+// digits. Long words are split, see searchWordMax.
+func searchWords(r io.Reader, fn func(w string)) error {
+	br := bufio.NewReader(r)
+	var word []rune
+	flush := func() {
+		for i := 0; ; i += searchWordMax / 2 {
+			end := min(i+searchWordMax, len(word))
+			if end > i {
+				fn(string(word[i:end]))
+			}
+			if end == len(word) {
+				break
+			}
+		}
+		word = word[:0]
+	}
+	for {
+		c, _, err := br.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			flush()
+			return err
+		}
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			word = append(word, unicode.ToLower(c))
+		} else if len(word) > 0 {
+			flush()
+		}
+	}
+	flush()
+	return nil
+}
+
+// searchIndexMessageWords returns the unique words in the message for the search
+// index. Errors reading parts of the message are logged, the words found so far
+// are returned.
+func searchIndexMessageWords(log mlog.Log, p *message.Part) map[string]struct{} {
+	words := map[string]struct{}{}
+	add := func(w string) {
+		words[w] = struct{}{}
+	}
+	addString := func(s string) {
+		searchWords(strings.NewReader(s), add)
+	}
+
+	if env := p.Envelope; env != nil {
+		addString(env.Subject)
+		for _, l := range [][]message.Address{env.From, env.Sender, env.ReplyTo, env.To, env.CC, env.BCC} {
+			for _, a := range l {
+				addString(a.Name)
+				addString(a.User)
+				addString(a.Host)
+			}
+		}
+	}
+
+	// We index the same data that is searched by WordSearch.MatchPart, with
+	// headers, and attachment filenames.
+	var index func(p *message.Part) error
+	index = func(p *message.Part) error {
+		if err := searchWords(p.HeaderReader(), add); err != nil {
+			return fmt.Errorf("reading header: %w", err)
+		}
+		if _, filename, _ := p.DispositionFilename(); filename != "" {
+			addString(filename)
+		}
+		if len(p.Parts) == 0 && p.MediaType == "TEXT" {
+			if err := searchWords(p.ReaderUTF8OrBinary(), add); err != nil {
+				return fmt.Errorf("reading text: %w", err)
+			}
+		} else if len(p.Parts) == 0 {
+			if text, ok := extractPartText(log, p); ok {
+				addString(text)
+			}
+		}
+		for _, pp := range p.Parts {
+			if pp.Message != nil {
+				if err := pp.SetMessageReaderAt(); err != nil {
+					return fmt.Errorf("setting reader for embedded message: %w", err)
+				}
+				pp = *pp.Message
+			}
+			if err := index(&pp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := index(p); err != nil {
+		log.Infox("reading message for search index, indexing words found so far", err)
+	}
+	return words
+}
+
+// searchIndexAdd adds the words of message m, with its parsed form p with reader,
+// to the full-text search index.
+func searchIndexAdd(log mlog.Log, tx *bstore.Tx, m *Message, p *message.Part) error {
+	up := Upgrade{ID: 1}
+	if err := tx.Get(&up); err != nil {
+		return fmt.Errorf("get upgrade state: %w", err)
+	}
+	words := searchIndexMessageWords(log, p)
+	if up.SearchIndexHashed {
+		hashed := map[string]struct{}{}
+		for w := range words {
+			hashed[SearchWordHash(up.SearchIndexSalt, w)] = struct{}{}
+		}
+		words = hashed
+	}
+	for _, w := range slices.Sorted(maps.Keys(words)) {
+		sw := SearchWord{w}
+		if err := tx.Get(&sw); err == bstore.ErrAbsent {
+			if err := tx.Insert(&sw); err != nil {
+				return fmt.Errorf("inserting word into search vocabulary: %w", err)
+			}
+		} else if err != nil {
+			return fmt.Errorf("get word from search vocabulary: %w", err)
+		}
+		if err := tx.Insert(&SearchWordMessage{Word: w, MessageID: m.ID}); err != nil {
+			return fmt.Errorf("inserting word for message into search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchIndexCopy adds the words of the message origID to the full-text search
+// index for a copy of the message with newID.
+func SearchIndexCopy(tx *bstore.Tx, origID, newID int64) error {
+	q := bstore.QueryTx[SearchWordMessage](tx)
+	q.FilterNonzero(SearchWordMessage{MessageID: origID})
+	l, err := q.List()
+	if err != nil {
+		return fmt.Errorf("listing search index words of message: %w", err)
+	}
+	for _, swm := range l {
+		if err := tx.Insert(&SearchWordMessage{Word: swm.Word, MessageID: newID}); err != nil {
+			return fmt.Errorf("inserting word for copied message into search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// SearchIndexCandidates returns the IDs of the messages that may contain all of
+// words, according to the full-text search index. The messages must still be
+// verified to actually contain the words. If the index cannot be used, e.g.
+// because it is still being built for the account, or none of the words can be
+// looked up, ok is false and all messages must be searched. A hashed index cannot
+// be used for substring matches, see SearchIndexHashedMatch.
+func SearchIndexCandidates(tx *bstore.Tx, words []string) (ids map[int64]struct{}, ok bool, rerr error) {
+	up := Upgrade{ID: 1}
+	if err := tx.Get(&up); err != nil {
+		return nil, false, fmt.Errorf("get upgrade state: %w", err)
+	}
+	if !up.SearchIndex || up.SearchIndexHashed {
+		return nil, false, nil
+	}
+
+	var lookup []string
+	for _, w := range words {
+		searchWords(strings.NewReader(w), func(sw string) {
+			n := len([]rune(sw))
+			if n >= searchWordMin && n <= searchWordMax/2 && !slices.Contains(lookup, sw) {
+				lookup = append(lookup, sw)
+			}
+		})
+	}
+	if len(lookup) == 0 {
+		return nil, false, nil
+	}
+
+	// Gather the words in the vocabulary that contain each of the lookup words.
+	matches := make([][]string, len(lookup))
+	err := bstore.QueryTx[SearchWord](tx).ForEach(func(sw SearchWord) error {
+		for i, w := range lookup {
+			if strings.Contains(sw.Word, w) {
+				matches[i] = append(matches[i], sw.Word)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, false, fmt.Errorf("searching vocabulary: %w", err)
+	}
+
+	// Intersect the messages having each lookup word.
+	for i, l := range matches {
+		nids := map[int64]struct{}{}
+		for _, w := range l {
+			q := bstore.QueryTx[SearchWordMessage](tx)
+			q.FilterNonzero(SearchWordMessage{Word: w})
+			err := q.ForEach(func(swm SearchWordMessage) error {
+				if _, ok := ids[swm.MessageID]; i == 0 || ok {
+					nids[swm.MessageID] = struct{}{}
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, false, fmt.Errorf("looking up messages in search index: %w", err)
+			}
+		}
+		ids = nids
+		if len(ids) == 0 {
+			break
+		}
+	}
+	return ids, true, nil
+}
+
+// SearchIndexHashedMatch returns a function that matches the IDs of messages
+// that have all words with hashes in hashes, and none of the words with hashes in
+// notHashes, according to the hashed full-text search index. The hashes are of
+// whole words, as computed with SearchWordHash by the client, with long words
+// split like searchWords does. The matches are not verified against the
+// messages. If the index is not hashed, an error is returned. If the index is
+// still being built, ErrSearchIndexIncomplete is returned.
+func SearchIndexHashedMatch(tx *bstore.Tx, hashes, notHashes []string) (func(id int64) bool, error) {
+	up := Upgrade{ID: 1}
+	if err := tx.Get(&up); err != nil {
+		return nil, fmt.Errorf("get upgrade state: %w", err)
+	}
+	if !up.SearchIndexHashed {
+		return nil, fmt.Errorf("search index does not have hashed words")
+	} else if !up.SearchIndex {
+		return nil, ErrSearchIndexIncomplete
+	}
+
+	messages := func(hash string) (map[int64]struct{}, error) {
+		ids := map[int64]struct{}{}
+		q := bstore.QueryTx[SearchWordMessage](tx)
+		q.FilterNonzero(SearchWordMessage{Word: hash})
+		err := q.ForEach(func(swm SearchWordMessage) error {
+			ids[swm.MessageID] = struct{}{}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("looking up messages in search index: %w", err)
+		}
+		return ids, nil
+	}
+
+	var ids map[int64]struct{} // Nil matches all messages.
+	for _, h := range hashes {
+		l, err := messages(h)
+		if err != nil {
+			return nil, err
+		}
+		if ids != nil {
+			for id := range ids {
+				if _, ok := l[id]; !ok {
+					delete(ids, id)
+				}
+			}
+		} else {
+			ids = l
+		}
+	}
+	notIDs := map[int64]struct{}{}
+	for _, h := range notHashes {
+		l, err := messages(h)
+		if err != nil {
+			return nil, err
+		}
+		maps.Copy(notIDs, l)
+	}
+	return func(id int64) bool {
+		if _, ok := notIDs[id]; ok {
+			return false
+		}
+		_, ok := ids[id]
+		return ids == nil || ok
+	}, nil
+}
+
+// SearchIndexHashedMessage returns whether message id has all words with hashes
+// in hashes and none in notHashes, like SearchIndexHashedMatch, for a single
+// message.
+func SearchIndexHashedMessage(tx *bstore.Tx, id int64, hashes, notHashes []string) (bool, error) {
+	up := Upgrade{ID: 1}
+	if err := tx.Get(&up); err != nil {
+		return false, fmt.Errorf("get upgrade state: %w", err)
+	}
+	if !up.SearchIndexHashed {
+		return false, fmt.Errorf("search index does not have hashed words")
+	} else if !up.SearchIndex {
+		return false, ErrSearchIndexIncomplete
+	}
+	has := func(hash string) (bool, error) {
+		exists, err := bstore.QueryTx[SearchWordMessage](tx).FilterNonzero(SearchWordMessage{Word: hash, MessageID: id}).Exists()
+		if err != nil {
+			return false, fmt.Errorf("looking up message in search index: %w", err)
+		}
+		return exists, nil
+	}
+	for _, h := range hashes {
+		if ok, err := has(h); err != nil || !ok {
+			return false, err
+		}
+	}
+	for _, h := range notHashes {
+		if ok, err := has(h); err != nil || ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// upgradeSearchIndex adds all messages that are not yet present to the full-text
+// search index, and marks the index as complete.
+func (a *Account) upgradeSearchIndex(ctx context.Context, log mlog.Log) (int, error) {
+	total := 0
+	var lastID int64 // Each db transaction starts after lastID.
+	for {
+		var n int
+		err := a.DB.Write(ctx, func(tx *bstore.Tx) error {
+			q := bstore.QueryTx[Message](tx)
+			q.FilterEqual("Expunged", false)
+			q.FilterGreater("ID", lastID)
+			q.Limit(searchIndexBatchSize)
+			q.SortAsc("ID")
+			return q.ForEach(func(m Message) error {
+				lastID = m.ID
+				n++
+
+				// Messages delivered since the upgrade started have already been added.
+				exists, err := bstore.QueryTx[SearchWordMessage](tx).FilterNonzero(SearchWordMessage{MessageID: m.ID}).Exists()
+				if err != nil {
+					return fmt.Errorf("checking if message is in search index: %w", err)
+				} else if exists {
+					return nil
+				}
+
+				mr := a.MessageReader(m)
+				defer func() {
+					err := mr.Close()
+					log.Check(err, "closing message reader after indexing")
+				}()
+				p, err := m.LoadPart(mr)
+				if err != nil {
+					log.Errorx("loading parsed message for search index, skipping", err, slog.Int64("msgid", m.ID))
+					return nil
+				}
+				return searchIndexAdd(log, tx, &m, &p)
+			})
+		})
+		total += n
+		if err != nil {
+			return total, fmt.Errorf("adding messages to search index: %w", err)
+		}
+		log.Debug("search index progress", slog.Int("total", total))
+		if n < searchIndexBatchSize {
+			break
+		}
+	}
+
+	err := a.DB.Write(ctx, func(tx *bstore.Tx) error {
+		up := Upgrade{ID: 1}
+		if err := tx.Get(&up); err != nil {
+			return fmt.Errorf("get upgrade state: %w", err)
+		}
+		up.SearchIndex = true
+		return tx.Update(&up)
+	})
+	if err != nil {
+		return total, fmt.Errorf("marking search index complete: %w", err)
+	}
+	return total, nil
+}
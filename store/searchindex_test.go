@@ -0,0 +1,239 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestSearchIndex(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	defer Switchboard()()
+
+	acc, err := OpenAccount(log, "mjl", true)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	deliver := func(s string) Message {
+		t.Helper()
+		f, err := CreateMessageTemp(log, "searchindex-test")
+		tcheck(t, err, "temp file")
+		defer CloseRemoveTempFile(log, f, "test message")
+
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+		m := Message{
+			Size:      int64(len(s)),
+			MsgPrefix: []byte(s),
+		}
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(log, "Inbox", &m, f)
+			tcheck(t, err, "deliver")
+		})
+		return m
+	}
+
+	long := strings.Repeat("abcdefghij", 10)
+	m0 := deliver("From: Café Owner <owner@cafe.example>\nSubject: Quarterly report\n\nThe numbers are in.\n")
+	m1 := deliver(`Subject: Lunch
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary=x
+
+--x
+Content-Type: text/plain
+
+See attachment, ` + long + `.
+--x
+Content-Type: application/pdf
+Content-Disposition: attachment; filename="menu.pdf"
+
+binarydata
+--x--
+`)
+
+	candidates := func(words ...string) []int64 {
+		t.Helper()
+		ids := []int64{}
+		var ok bool
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			m, xok, err := SearchIndexCandidates(tx, words)
+			ok = xok
+			for id := range m {
+				ids = append(ids, id)
+			}
+			return err
+		})
+		tcheck(t, err, "search index candidates")
+		if !ok {
+			return nil
+		}
+		slices.Sort(ids)
+		return ids
+	}
+
+	tcompare(t, candidates("quarter"), []int64{m0.ID})
+	tcompare(t, candidates("CAFÉ"), []int64{m0.ID})
+	tcompare(t, candidates("owner@cafe"), []int64{m0.ID})
+	tcompare(t, candidates("report", "lunch"), []int64{})
+	tcompare(t, candidates("menu.pdf"), []int64{m1.ID})
+	tcompare(t, candidates("attachment"), []int64{m1.ID})
+	tcompare(t, candidates(long[37:67]), []int64{m1.ID})
+	tcompare(t, candidates("binarydata"), []int64{})
+	// Too short or too long to look up.
+	tcompare(t, candidates("in"), []int64(nil))
+	tcompare(t, candidates(long), []int64(nil))
+
+	// Removed messages are gone from the index.
+	acc.WithWLock(func() {
+		var changes []Change
+		err := acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+			mb, err := acc.MailboxFind(tx, "Inbox")
+			tcheck(t, err, "get mailbox")
+			modseq, err := acc.NextModSeq(tx)
+			tcheck(t, err, "next modseq")
+			m := Message{ID: m0.ID}
+			err = tx.Get(&m)
+			tcheck(t, err, "get message")
+			chrem, chmbc, err := acc.MessageRemove(log, tx, modseq, mb, RemoveOpts{}, m)
+			tcheck(t, err, "remove message")
+			changes = append(changes, chrem, chmbc)
+			return tx.Update(mb)
+		})
+		tcheck(t, err, "remove message")
+		BroadcastChanges(acc, changes)
+	})
+	tcompare(t, candidates("quarter"), []int64{})
+
+	// During upgrade, the index cannot be used.
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		if _, err := bstore.QueryTx[SearchWordMessage](tx).Delete(); err != nil {
+			return err
+		}
+		up := Upgrade{ID: 1}
+		if err := tx.Get(&up); err != nil {
+			return err
+		}
+		up.SearchIndex = false
+		return tx.Update(&up)
+	})
+	tcheck(t, err, "clear search index")
+	tcompare(t, candidates("lunch"), []int64(nil))
+
+	total, err := acc.upgradeSearchIndex(ctxbg, log)
+	tcheck(t, err, "upgrade search index")
+	tcompare(t, total, 1)
+	tcompare(t, candidates("lunch"), []int64{m1.ID})
+	tcompare(t, candidates("quarter"), []int64{})
+}
+
+func TestSearchIndexHashed(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	mox.Conf.Static.SearchIndexHashed = true
+	defer func() {
+		mox.Conf.Static.SearchIndexHashed = false
+	}()
+	defer Switchboard()()
+
+	acc, err := OpenAccount(log, "mjl", true)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	var salt []byte
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		salt, err = SearchIndexSalt(tx)
+		return err
+	})
+	tcheck(t, err, "get salt")
+	if len(salt) == 0 {
+		t.Fatalf("missing salt for hashed search index")
+	}
+
+	deliver := func(s string) Message {
+		t.Helper()
+		f, err := CreateMessageTemp(log, "searchindex-test")
+		tcheck(t, err, "temp file")
+		defer CloseRemoveTempFile(log, f, "test message")
+
+		s = strings.ReplaceAll(s, "\n", "\r\n")
+		m := Message{
+			Size:      int64(len(s)),
+			MsgPrefix: []byte(s),
+		}
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(log, "Inbox", &m, f)
+			tcheck(t, err, "deliver")
+		})
+		return m
+	}
+
+	m0 := deliver("Subject: Quarterly report\n\nThe numbers are in.\n")
+	m1 := deliver("Subject: Lunch report\n\nSee you at noon.\n")
+
+	hashes := func(words ...string) []string {
+		var l []string
+		for _, w := range words {
+			l = append(l, SearchWordHash(salt, w))
+		}
+		return l
+	}
+
+	match := func(words, notWords []string) []int64 {
+		t.Helper()
+		ids := []int64{}
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			fn, err := SearchIndexHashedMatch(tx, hashes(words...), hashes(notWords...))
+			tcheck(t, err, "hashed match")
+			for _, m := range []Message{m0, m1} {
+				if fn(m.ID) {
+					ids = append(ids, m.ID)
+				}
+				ok, err := SearchIndexHashedMessage(tx, m.ID, hashes(words...), hashes(notWords...))
+				tcheck(t, err, "hashed message")
+				tcompare(t, ok, fn(m.ID))
+			}
+			return nil
+		})
+		tcheck(t, err, "search index")
+		return ids
+	}
+
+	tcompare(t, match([]string{"report"}, nil), []int64{m0.ID, m1.ID})
+	tcompare(t, match([]string{"report"}, []string{"lunch"}), []int64{m0.ID})
+	tcompare(t, match([]string{"quarterly", "report"}, nil), []int64{m0.ID})
+	// Only whole words are present in a hashed index.
+	tcompare(t, match([]string{"quarter"}, nil), []int64{})
+	tcompare(t, match(nil, nil), []int64{m0.ID, m1.ID})
+
+	// Plain words cannot be looked up, all messages must be searched.
+	err = acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+		_, ok, err := SearchIndexCandidates(tx, []string{"report"})
+		tcompare(t, ok, false)
+		return err
+	})
+	tcheck(t, err, "search index candidates")
+
+	// The vocabulary doesn't have the words in plain text.
+	exists, err := bstore.QueryDB[SearchWord](ctxbg, acc.DB).FilterNonzero(SearchWord{"report"}).Exists()
+	tcheck(t, err, "lookup word")
+	tcompare(t, exists, false)
+}
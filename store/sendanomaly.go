@@ -0,0 +1,258 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+// OutgoingFailure is a recipient of a message submitted by the account for which
+// delivery from the queue failed permanently, because the remote server rejected
+// it. Used to detect a high rate of failed recipients, a sign of account
+// compromise.
+type OutgoingFailure struct {
+	ID        int64
+	Recipient string    // Canonical international address with utf8 domain.
+	Failed    time.Time `bstore:"nonzero,default now,index"`
+	Secode    string    // Enhanced status code from remote, e.g. "1.1" for unknown user. Can be empty.
+}
+
+// SubmitNetwork is a network from which the account submitted messages over SMTP,
+// for detecting submissions from unusual networks.
+type SubmitNetwork struct {
+	ID      int64
+	Network string    `bstore:"nonzero,unique"` // IPv4 /24 or IPv6 /48, e.g. "192.0.2.0" or "2001:db8::".
+	First   time.Time `bstore:"nonzero"`
+	Last    time.Time `bstore:"nonzero"`
+}
+
+// SendSuspension is set when sending was suspended for the account, after
+// detecting an anomaly in submitted messages. Submissions are rejected until an
+// admin resumes sending.
+type SendSuspension struct {
+	ID        uint8 // Singleton ID 1.
+	Suspended time.Time
+	Reason    string
+}
+
+// Kinds of outgoing anomalies.
+const (
+	SendAnomalyVolume  = "volume"  // Spike in number of messages.
+	SendAnomalyFailed  = "failed"  // High rate of failed recipients.
+	SendAnomalyNetwork = "network" // Submission from unusual network.
+	SendAnomalyHour    = "hour"    // Submission at unusual hour of day.
+)
+
+// SendAnomaly is an anomaly detected for a submission.
+type SendAnomaly struct {
+	Kind string // SendAnomalyVolume, etc.
+	Text string // Human-readable explanation.
+
+	// Whether the anomaly is cause for throttling or suspending sending, depending on
+	// the configured action. Unusual networks and hours only cause alerts.
+	Block bool
+}
+
+// Periods over which anomalies are evaluated.
+const (
+	sendAnomalyBaselinePeriod = 7 * 24 * time.Hour
+	sendAnomalyUnusualPeriod  = 30 * 24 * time.Hour
+	sendAnomalyMinHistory     = 100 // Messages in unusual period before unusual hours are detected.
+)
+
+// OutgoingFailureAdd registers a permanent delivery failure for a recipient of a
+// message submitted by the account. Failures older than the baseline period are
+// removed.
+func (a *Account) OutgoingFailureAdd(ctx context.Context, recipient, secode string) error {
+	return a.DB.Write(ctx, func(tx *bstore.Tx) error {
+		_, err := bstore.QueryTx[OutgoingFailure](tx).FilterLess("Failed", time.Now().Add(-sendAnomalyBaselinePeriod)).Delete()
+		if err != nil {
+			return fmt.Errorf("removing old outgoing failures: %v", err)
+		}
+		return tx.Insert(&OutgoingFailure{Recipient: recipient, Secode: secode})
+	})
+}
+
+// SendSuspensionGet returns the suspension of sending for the account, or nil if
+// sending is not suspended.
+func (a *Account) SendSuspensionGet(ctx context.Context) (*SendSuspension, error) {
+	ss := SendSuspension{ID: 1}
+	err := a.DB.Get(ctx, &ss)
+	if errors.Is(err, bstore.ErrAbsent) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	return &ss, nil
+}
+
+// SendResume resumes sending for the account after a suspension. Not an error if
+// sending was not suspended.
+func (a *Account) SendResume(ctx context.Context) error {
+	err := a.DB.Delete(ctx, &SendSuspension{ID: 1})
+	if err != nil && !errors.Is(err, bstore.ErrAbsent) {
+		return err
+	}
+	return nil
+}
+
+// submitNetwork returns the network of ip that is tracked for submissions.
+func submitNetwork(ip net.IP) string {
+	if ip.To4() != nil {
+		return ip.Mask(net.CIDRMask(24, 32)).String()
+	}
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
+
+// SendAnomalyCheck checks a submission of a message to recipients for anomalies,
+// as configured with OutgoingAnomalies. If sending is suspended for the account,
+// the suspension is returned and no anomalies are checked. If the configured
+// action is to suspend, and a blocking anomaly is found, sending is suspended and
+// the new suspension is returned. RemoteIP is only set for SMTP submissions, and
+// networks of submissions are registered.
+func (a *Account) SendAnomalyCheck(ctx context.Context, recipients []smtp.Path, remoteIP net.IP) (anomalies []SendAnomaly, suspension *SendSuspension, rerr error) {
+	conf := mox.Conf.Static.OutgoingAnomalies
+
+	rerr = a.DB.Write(ctx, func(tx *bstore.Tx) error {
+		ss := SendSuspension{ID: 1}
+		if err := tx.Get(&ss); err == nil {
+			suspension = &ss
+			return nil
+		} else if !errors.Is(err, bstore.ErrAbsent) {
+			return fmt.Errorf("get send suspension: %v", err)
+		}
+		if conf == nil {
+			return nil
+		}
+
+		now := time.Now()
+		hour := now.Add(-time.Hour)
+		day := now.Add(-24 * time.Hour)
+		baseline := now.Add(-sendAnomalyBaselinePeriod)
+		unusual := now.Add(-sendAnomalyUnusualPeriod)
+
+		// Gather counts of outgoing messages, per period, and the hours of the day at
+		// which messages were submitted.
+		var nhour, nday, nbaseline, nunusual int
+		var hours [24]bool
+		var history bool // Whether there are messages from before the baseline period.
+		start := baseline
+		if conf.UnusualHours || remoteIP != nil && conf.UnusualNetworks {
+			start = unusual
+		}
+		err := bstore.QueryTx[Outgoing](tx).FilterGreater("Submitted", start).ForEach(func(o Outgoing) error {
+			if o.Submitted.After(hour) {
+				nhour++
+			} else if o.Submitted.After(baseline) {
+				nbaseline++
+			}
+			if o.Submitted.After(day) {
+				nday++
+			}
+			if o.Submitted.Before(baseline) {
+				history = true
+			}
+			nunusual++
+			hours[o.Submitted.Hour()] = true
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("counting outgoing messages: %v", err)
+		}
+		nhour += len(recipients)
+
+		// Volume spike, compared to hourly average over the baseline period.
+		factor := conf.VolumeFactor
+		if factor == 0 {
+			factor = 10
+		}
+		minimum := conf.VolumeMinimum
+		if minimum == 0 {
+			minimum = 50
+		}
+		avg := float64(nbaseline) / (sendAnomalyBaselinePeriod.Hours() - 1)
+		if nhour >= minimum && float64(nhour) > float64(factor)*max(avg, 1) {
+			text := fmt.Sprintf("%d messages in the past hour, hourly average over the past 7 days is %.1f", nhour, avg)
+			anomalies = append(anomalies, SendAnomaly{SendAnomalyVolume, text, true})
+		}
+
+		// Failed recipients over the past day.
+		pct := conf.FailedPercentage
+		if pct == 0 {
+			pct = 30
+		}
+		minFailed := conf.FailedMinimum
+		if minFailed == 0 {
+			minFailed = 10
+		}
+		nfailed, err := bstore.QueryTx[OutgoingFailure](tx).FilterGreater("Failed", day).Count()
+		if err != nil {
+			return fmt.Errorf("counting outgoing failures: %v", err)
+		}
+		if nfailed >= minFailed && nfailed*100 >= pct*max(nday, 1) {
+			text := fmt.Sprintf("delivery failed for %d recipients in the past 24 hours, of %d messages submitted", nfailed, nday)
+			anomalies = append(anomalies, SendAnomaly{SendAnomalyFailed, text, true})
+		}
+
+		if conf.UnusualHours && nunusual >= sendAnomalyMinHistory && !hours[now.Hour()] {
+			text := fmt.Sprintf("submission at hour %d, no submissions at this hour in the past 30 days", now.Hour())
+			anomalies = append(anomalies, SendAnomaly{SendAnomalyHour, text, false})
+		}
+
+		if remoteIP != nil {
+			network := submitNetwork(remoteIP)
+			sn, err := bstore.QueryTx[SubmitNetwork](tx).FilterNonzero(SubmitNetwork{Network: network}).Get()
+			if errors.Is(err, bstore.ErrAbsent) {
+				sn = SubmitNetwork{Network: network, First: now, Last: now}
+				if err := tx.Insert(&sn); err != nil {
+					return fmt.Errorf("adding submit network: %v", err)
+				}
+				if conf.UnusualNetworks && history {
+					text := fmt.Sprintf("submission from network %s, not used before", network)
+					anomalies = append(anomalies, SendAnomaly{SendAnomalyNetwork, text, false})
+				}
+			} else if err != nil {
+				return fmt.Errorf("get submit network: %v", err)
+			} else {
+				if conf.UnusualNetworks && history && sn.Last.Before(unusual) {
+					text := fmt.Sprintf("submission from network %s, last used %s", network, sn.Last.Format(time.DateOnly))
+					anomalies = append(anomalies, SendAnomaly{SendAnomalyNetwork, text, false})
+				}
+				// Prevent a write for each submission.
+				if sn.Last.Before(hour) {
+					sn.Last = now
+					if err := tx.Update(&sn); err != nil {
+						return fmt.Errorf("updating submit network: %v", err)
+					}
+				}
+			}
+		}
+
+		if conf.Action != config.OutgoingAnomalySuspend {
+			return nil
+		}
+		for _, an := range anomalies {
+			if an.Block {
+				ss = SendSuspension{ID: 1, Suspended: now, Reason: an.Text}
+				if err := tx.Insert(&ss); err != nil {
+					return fmt.Errorf("adding send suspension: %v", err)
+				}
+				suspension = &ss
+				break
+			}
+		}
+		return nil
+	})
+	if rerr != nil {
+		return nil, nil, rerr
+	}
+	return
+}
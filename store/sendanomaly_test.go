@@ -0,0 +1,121 @@
+package store
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestSendAnomaly(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	rcpts := []smtp.Path{{Localpart: "remote", IPDomain: dns.IPDomain{Domain: dns.Domain{ASCII: "example.org"}}}}
+
+	check := func(remoteIP net.IP, expKinds []string, expSuspended bool) {
+		t.Helper()
+		anomalies, ss, err := acc.SendAnomalyCheck(ctxbg, rcpts, remoteIP)
+		tcheck(t, err, "check send anomalies")
+		var kinds []string
+		for _, an := range anomalies {
+			kinds = append(kinds, an.Kind)
+		}
+		tcompare(t, kinds, expKinds)
+		tcompare(t, ss != nil, expSuspended)
+	}
+
+	addOutgoing := func(n int, submitted time.Time) {
+		t.Helper()
+		err := acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+			for range n {
+				err := tx.Insert(&Outgoing{Recipient: "remote@example.org", Submitted: submitted})
+				tcheck(t, err, "insert outgoing")
+			}
+			return nil
+		})
+		tcheck(t, err, "write")
+	}
+
+	// Not configured, nothing is checked.
+	addOutgoing(10, time.Now())
+	check(nil, nil, false)
+
+	mox.Conf.Static.OutgoingAnomalies = &config.OutgoingAnomalies{VolumeMinimum: 10, FailedMinimum: 3, UnusualNetworks: true}
+	defer func() {
+		mox.Conf.Static.OutgoingAnomalies = nil
+	}()
+
+	// Volume spike, no history.
+	check(nil, []string{SendAnomalyVolume}, false)
+
+	// Enough messages during baseline period, not a spike anymore.
+	addOutgoing(300, time.Now().Add(-2*24*time.Hour))
+	check(nil, nil, false)
+
+	// Failed recipients.
+	for range 5 {
+		err := acc.OutgoingFailureAdd(ctxbg, "remote@example.org", "1.1")
+		tcheck(t, err, "add outgoing failure")
+	}
+	check(nil, []string{SendAnomalyFailed}, false)
+	_, err = bstore.QueryDB[OutgoingFailure](ctxbg, acc.DB).Delete()
+	tcheck(t, err, "removing outgoing failures")
+
+	// First network is registered, but the account has no history yet.
+	check(net.ParseIP("192.0.2.1"), nil, false)
+
+	// With history, new networks are unusual. Known networks are not.
+	addOutgoing(1, time.Now().Add(-10*24*time.Hour))
+	check(net.ParseIP("192.0.2.2"), nil, false)
+	check(net.ParseIP("198.51.100.1"), []string{SendAnomalyNetwork}, false)
+	check(net.ParseIP("198.51.100.1"), nil, false)
+
+	// Unusual networks don't cause suspension, failed recipients do.
+	mox.Conf.Static.OutgoingAnomalies.Action = config.OutgoingAnomalySuspend
+	check(net.ParseIP("203.0.113.1"), []string{SendAnomalyNetwork}, false)
+	for range 5 {
+		err := acc.OutgoingFailureAdd(ctxbg, "remote@example.org", "1.1")
+		tcheck(t, err, "add outgoing failure")
+	}
+	check(nil, []string{SendAnomalyFailed}, true)
+
+	// While suspended, no anomalies are checked.
+	check(nil, nil, true)
+	ss, err := acc.SendSuspensionGet(ctxbg)
+	tcheck(t, err, "get send suspension")
+	tcompare(t, ss != nil, true)
+
+	err = acc.SendResume(ctxbg)
+	tcheck(t, err, "resume sending")
+	ss, err = acc.SendSuspensionGet(ctxbg)
+	tcheck(t, err, "get send suspension")
+	tcompare(t, ss == nil, true)
+	err = acc.SendResume(ctxbg)
+	tcheck(t, err, "resume sending without suspension")
+}
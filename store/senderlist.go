@@ -0,0 +1,161 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/smtp"
+)
+
+// SenderListAction is the action for messages from a sender matching an entry
+// in the sender lists of an account.
+type SenderListAction string
+
+const (
+	SenderListAllow   SenderListAction = "allow"   // Accept without junk filtering, if the message From address is verified.
+	SenderListReject  SenderListAction = "reject"  // Reject during SMTP.
+	SenderListDiscard SenderListAction = "discard" // Accept during SMTP, but don't deliver.
+)
+
+// SenderListEntry is an entry in the allow or block list of senders of an
+// account, matched against the message From address of incoming messages, and
+// for rejects and discards also against the SMTP MAIL FROM address.
+type SenderListEntry struct {
+	ID      int64
+	Created time.Time `bstore:"default now"`
+
+	// Lower case address like "user@example.org", domain like "example.org", or
+	// wildcard domain like "*.example.org" matching the domain and all its
+	// subdomains. Domains in unicode.
+	Pattern string `bstore:"nonzero,unique"`
+
+	Action SenderListAction `bstore:"nonzero"`
+}
+
+// SenderAddress returns the address as used in a SenderListEntry pattern, or an
+// empty string if there is no address.
+func SenderAddress(localpart smtp.Localpart, domain string) string {
+	if domain == "" {
+		return ""
+	}
+	return strings.ToLower(localpart.String() + "@" + domain)
+}
+
+// SenderListPattern parses an address, domain or wildcard domain, returning the
+// pattern as stored in a SenderListEntry.
+func SenderListPattern(s string) (string, error) {
+	s = strings.TrimSpace(s)
+	// "*@example.org" is taken to mean all addresses of the domain.
+	s = strings.TrimPrefix(s, "*@")
+	if strings.Contains(s, "@") {
+		addr, err := smtp.ParseAddress(s)
+		if err != nil {
+			return "", fmt.Errorf("parsing address: %v", err)
+		} else if addr.Domain.IsZero() {
+			return "", fmt.Errorf("parsing address: missing domain")
+		}
+		return SenderAddress(addr.Localpart, addr.Domain.Name()), nil
+	}
+	wildcard := strings.HasPrefix(s, "*.")
+	d, err := dns.ParseDomain(strings.TrimPrefix(s, "*."))
+	if err != nil {
+		return "", fmt.Errorf("parsing domain: %v", err)
+	} else if d.IsZero() {
+		return "", errors.New("missing address or domain")
+	}
+	if wildcard {
+		return "*." + d.Name(), nil
+	}
+	return d.Name(), nil
+}
+
+// SenderListMatch returns the most specific entry in the sender lists matching
+// the address, or nil if none matches. An address entry takes precedence over a
+// domain entry, which takes precedence over wildcard domains, longest first.
+func SenderListMatch(tx *bstore.Tx, localpart smtp.Localpart, domain string) (*SenderListEntry, error) {
+	addr := SenderAddress(localpart, domain)
+	if addr == "" {
+		return nil, nil
+	}
+	domain = strings.ToLower(domain)
+	patterns := []string{addr, domain}
+	for d := domain; d != ""; {
+		patterns = append(patterns, "*."+d)
+		_, d, _ = strings.Cut(d, ".")
+	}
+	for _, p := range patterns {
+		e, err := bstore.QueryTx[SenderListEntry](tx).FilterNonzero(SenderListEntry{Pattern: p}).Get()
+		if err == nil {
+			return &e, nil
+		} else if err != bstore.ErrAbsent {
+			return nil, fmt.Errorf("looking up sender list entry: %v", err)
+		}
+	}
+	return nil, nil
+}
+
+// SenderListSave adds a pattern (address, domain or wildcard domain) to the
+// sender lists, or changes the action for an existing entry.
+func (a *Account) SenderListSave(log mlog.Log, pattern string, action SenderListAction) (SenderListEntry, error) {
+	switch action {
+	case SenderListAllow, SenderListReject, SenderListDiscard:
+	default:
+		return SenderListEntry{}, fmt.Errorf("unknown sender list action %q", action)
+	}
+	p, err := SenderListPattern(pattern)
+	if err != nil {
+		return SenderListEntry{}, err
+	}
+	var e SenderListEntry
+	err = a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		e, err = senderListSave(tx, p, action)
+		return err
+	})
+	if err != nil {
+		return SenderListEntry{}, err
+	}
+	log.Info("sender list entry saved", slog.String("account", a.Name), slog.String("pattern", e.Pattern), slog.Any("action", action))
+	return e, nil
+}
+
+func senderListSave(tx *bstore.Tx, pattern string, action SenderListAction) (SenderListEntry, error) {
+	e, err := bstore.QueryTx[SenderListEntry](tx).FilterNonzero(SenderListEntry{Pattern: pattern}).Get()
+	if err == bstore.ErrAbsent {
+		e = SenderListEntry{Pattern: pattern, Action: action}
+		if err := tx.Insert(&e); err != nil {
+			return SenderListEntry{}, fmt.Errorf("inserting sender list entry: %v", err)
+		}
+		return e, nil
+	} else if err != nil {
+		return SenderListEntry{}, fmt.Errorf("looking up sender list entry: %v", err)
+	}
+	if e.Action != action {
+		e.Action = action
+		if err := tx.Update(&e); err != nil {
+			return SenderListEntry{}, fmt.Errorf("updating sender list entry: %v", err)
+		}
+	}
+	return e, nil
+}
+
+// SenderListRemove removes an entry from the sender lists.
+func (a *Account) SenderListRemove(log mlog.Log, id int64) error {
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		return tx.Delete(&SenderListEntry{ID: id})
+	})
+	if err == bstore.ErrAbsent {
+		return errors.New("sender list entry not found")
+	} else if err != nil {
+		return err
+	}
+	log.Info("sender list entry removed", slog.String("account", a.Name), slog.Int64("id", id))
+	return nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestSenderListPattern(t *testing.T) {
+	test := func(s, exp string, expErr bool) {
+		t.Helper()
+		p, err := SenderListPattern(s)
+		if (err != nil) != expErr {
+			t.Fatalf("pattern %q: got err %v, expected error %v", s, err, expErr)
+		}
+		tcompare(t, p, exp)
+	}
+	test("User@Example.ORG", "user@example.org", false)
+	test(" example.org ", "example.org", false)
+	test("*.Example.org", "*.example.org", false)
+	test("bogus@", "", true)
+	test("*.", "", true)
+	test("*@example.org", "example.org", false)
+}
+
+func TestSenderList(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	match := func(lp smtp.Localpart, domain, expPattern string) {
+		t.Helper()
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			e, err := SenderListMatch(tx, lp, domain)
+			tcheck(t, err, "match")
+			var pattern string
+			if e != nil {
+				pattern = e.Pattern
+			}
+			tcompare(t, pattern, expPattern)
+			return nil
+		})
+		tcheck(t, err, "read")
+	}
+
+	_, err = acc.SenderListSave(log, "*.example.org", SenderListReject)
+	tcheck(t, err, "save wildcard")
+	_, err = acc.SenderListSave(log, "*.sub.example.org", SenderListDiscard)
+	tcheck(t, err, "save sub wildcard")
+	_, err = acc.SenderListSave(log, "sub.example.org", SenderListAllow)
+	tcheck(t, err, "save domain")
+	e, err := acc.SenderListSave(log, "user@sub.example.org", SenderListReject)
+	tcheck(t, err, "save address")
+
+	match("User", "sub.example.org", "user@sub.example.org")
+	match("other", "sub.example.org", "sub.example.org")
+	match("other", "a.sub.example.org", "*.sub.example.org")
+	match("other", "example.org", "*.example.org")
+	match("other", "other.example", "")
+	match("", "", "")
+
+	// Changing the action of an existing entry.
+	e2, err := acc.SenderListSave(log, "User@sub.example.org", SenderListAllow)
+	tcheck(t, err, "save address again")
+	tcompare(t, e2.ID, e.ID)
+	tcompare(t, e2.Action, SenderListAllow)
+
+	_, err = acc.SenderListSave(log, "example.org", "bogus")
+	if err == nil {
+		t.Fatalf("saving with unknown action succeeded")
+	}
+
+	err = acc.SenderListRemove(log, e.ID)
+	tcheck(t, err, "remove")
+	err = acc.SenderListRemove(log, e.ID)
+	if err == nil {
+		t.Fatalf("removing absent entry succeeded")
+	}
+	match("user", "sub.example.org", "sub.example.org")
+}
@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"log/slog"
 	"runtime/debug"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,6 +19,10 @@ import (
 	"github.com/mjl-/mox/mox-"
 )
 
+// ErrSessionUnknown is returned when getting or removing a session that does not
+// exist.
+var ErrSessionUnknown = errors.New("unknown session")
+
 const sessionsPerAccount = 100            // We remove the oldest when 100th is added.
 const sessionLifetime = 24 * time.Hour    // Extended automatically by use.
 const sessionWriteDelay = 5 * time.Minute // Per account, for coalescing writes.
@@ -102,6 +107,8 @@ func sessionUse(ctx context.Context, log mlog.Log, accountName string, sessionTo
 	ls, ok := sessions.accounts[accountName][sessionToken]
 	if !ok {
 		return LoginSession{}, fmt.Errorf("unknown session token")
+	} else if !ls.ImpersonationEnd.IsZero() && time.Until(ls.ImpersonationEnd) < 0 {
+		return LoginSession{}, fmt.Errorf("impersonation session ended")
 	} else if time.Until(ls.Expires) < 0 {
 		return LoginSession{}, fmt.Errorf("session expired (after 24 hours inactivity)")
 	} else if csrfToken != "" && csrfToken != ls.csrfToken {
@@ -110,6 +117,9 @@ func sessionUse(ctx context.Context, log mlog.Log, accountName string, sessionTo
 
 	// Extend lifetime.
 	ls.Expires = time.Now().Add(sessionLifetime)
+	if !ls.ImpersonationEnd.IsZero() && ls.Expires.After(ls.ImpersonationEnd) {
+		ls.Expires = ls.ImpersonationEnd
+	}
 	sessions.accounts[accountName][sessionToken] = ls
 
 	// If we haven't scheduled a flush to database yet, schedule one now.
@@ -241,10 +251,42 @@ func sessionAddToken(ctx context.Context, log mlog.Log, acc *Account, ls *LoginS
 
 // SessionAdd creates a new session token, with csrf token, and adds it to the
 // database and in-memory session cache. If there are too many sessions, the oldest
-// is removed.
-func SessionAdd(ctx context.Context, log mlog.Log, accountName, loginAddress string) (session SessionToken, csrf CSRFToken, rerr error) {
-	// Prepare new LoginSession.
-	ls := LoginSession{0, time.Time{}, time.Now().Add(sessionLifetime), [16]byte{}, [16]byte{}, accountName, loginAddress, "", ""}
+// is removed. Kind, remoteIP and userAgent are stored for listing sessions.
+func SessionAdd(ctx context.Context, log mlog.Log, accountName, loginAddress, kind, remoteIP, userAgent string) (session SessionToken, csrf CSRFToken, rerr error) {
+	ls := LoginSession{
+		Expires:      time.Now().Add(sessionLifetime),
+		AccountName:  accountName,
+		LoginAddress: loginAddress,
+		Kind:         kind,
+		RemoteIP:     remoteIP,
+		UserAgent:    userAgent,
+	}
+	return sessionAdd(ctx, log, ls)
+}
+
+// SessionAddImpersonation adds a session for an admin acting as the account, like
+// SessionAdd. See LoginSession.ImpersonatedBy. The session cannot be used after
+// end.
+func SessionAddImpersonation(ctx context.Context, log mlog.Log, accountName, loginAddress, kind, remoteIP, userAgent, impersonatedBy string, readOnly bool, end time.Time) (session SessionToken, csrf CSRFToken, rerr error) {
+	expires := time.Now().Add(sessionLifetime)
+	if expires.After(end) {
+		expires = end
+	}
+	ls := LoginSession{
+		Expires:          expires,
+		AccountName:      accountName,
+		LoginAddress:     loginAddress,
+		Kind:             kind,
+		RemoteIP:         remoteIP,
+		UserAgent:        userAgent,
+		ImpersonatedBy:   impersonatedBy,
+		ImpersonationEnd: end,
+		ReadOnly:         readOnly,
+	}
+	return sessionAdd(ctx, log, ls)
+}
+
+func sessionAdd(ctx context.Context, log mlog.Log, ls LoginSession) (session SessionToken, csrf CSRFToken, rerr error) {
 	if _, err := cryptorand.Read(ls.SessionTokenBinary[:]); err != nil {
 		return "", "", err
 	}
@@ -257,7 +299,7 @@ func SessionAdd(ctx context.Context, log mlog.Log, accountName, loginAddress str
 	sessions.Lock()
 	defer sessions.Unlock()
 
-	acc, err := ensureAccountSessions(ctx, log, accountName, true)
+	acc, err := ensureAccountSessions(ctx, log, ls.AccountName, true)
 	if err != nil {
 		return "", "", err
 	}
@@ -273,6 +315,37 @@ func SessionAdd(ctx context.Context, log mlog.Log, accountName, loginAddress str
 	return ls.sessionToken, ls.csrfToken, nil
 }
 
+// SessionGet returns a valid session, without extending its lifetime.
+func SessionGet(ctx context.Context, log mlog.Log, accountName string, sessionToken SessionToken) (LoginSession, error) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	if _, err := ensureAccountSessions(ctx, log, accountName, false); err != nil {
+		return LoginSession{}, err
+	}
+	ls, ok := sessions.accounts[accountName][sessionToken]
+	if !ok || time.Until(ls.Expires) < 0 || !ls.ImpersonationEnd.IsZero() && time.Until(ls.ImpersonationEnd) < 0 {
+		return LoginSession{}, fmt.Errorf("unknown or expired session token")
+	}
+	return ls, nil
+}
+
+// SessionGetID returns a valid session by its ID, without extending its lifetime.
+func SessionGetID(ctx context.Context, log mlog.Log, accountName string, id int64) (LoginSession, error) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	if _, err := ensureAccountSessions(ctx, log, accountName, false); err != nil {
+		return LoginSession{}, err
+	}
+	for _, ls := range sessions.accounts[accountName] {
+		if ls.ID == id && time.Until(ls.Expires) >= 0 && (ls.ImpersonationEnd.IsZero() || time.Until(ls.ImpersonationEnd) >= 0) {
+			return ls, nil
+		}
+	}
+	return LoginSession{}, ErrSessionUnknown
+}
+
 // SessionRemove removes a session from the database and in-memory cache. Future
 // operations using the session token will fail.
 func SessionRemove(ctx context.Context, log mlog.Log, accountName string, sessionToken SessionToken) error {
@@ -306,6 +379,54 @@ func SessionRemove(ctx context.Context, log mlog.Log, accountName string, sessio
 	return nil
 }
 
+// LastUsed returns when the session was last used, derived from its expiration
+// time, which is extended on each use.
+func (ls LoginSession) LastUsed() time.Time {
+	return ls.Expires.Add(-sessionLifetime)
+}
+
+// SessionList returns the sessions of an account that have not expired, most
+// recently used first.
+func SessionList(ctx context.Context, log mlog.Log, accountName string) ([]LoginSession, error) {
+	sessions.Lock()
+	defer sessions.Unlock()
+
+	if _, err := ensureAccountSessions(ctx, log, accountName, false); err != nil {
+		return nil, err
+	}
+
+	l := []LoginSession{}
+	for _, ls := range sessions.accounts[accountName] {
+		if time.Until(ls.Expires) >= 0 {
+			l = append(l, ls)
+		}
+	}
+	sort.Slice(l, func(i, j int) bool {
+		return l[i].Expires.After(l[j].Expires)
+	})
+	return l, nil
+}
+
+// SessionRemoveID removes a session by its ID, e.g. to revoke a session of
+// another device. Future operations using the session token will fail.
+func SessionRemoveID(ctx context.Context, log mlog.Log, accountName string, id int64) error {
+	sessions.Lock()
+	_, err := ensureAccountSessions(ctx, log, accountName, false)
+	var sessionToken SessionToken
+	for _, ls := range sessions.accounts[accountName] {
+		if ls.ID == id {
+			sessionToken = ls.sessionToken
+		}
+	}
+	sessions.Unlock()
+	if err != nil {
+		return err
+	} else if sessionToken == "" {
+		return ErrSessionUnknown
+	}
+	return SessionRemove(ctx, log, accountName, sessionToken)
+}
+
 // sessionRemoveAll removes all session tokens for an account. Useful after a password reset.
 func sessionRemoveAll(ctx context.Context, log mlog.Log, tx *bstore.Tx, accountName string) error {
 	sessions.Lock()
@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mjl-/bstore"
+)
+
+// SignupInvite is an invitation for self-service signup of an account, created
+// by an admin. Only a hash of the token is stored.
+type SignupInvite struct {
+	ID        int64
+	Created   time.Time `bstore:"nonzero,default now"`
+	Domain    string    `bstore:"nonzero"` // Domain for the address of new accounts.
+	TokenHash string    `bstore:"nonzero,unique" json:"-"`
+	Expires   time.Time `bstore:"nonzero"`
+	MaxUses   int       // Number of signups that can use the invite.
+	Uses      int       // Number of signups that used the invite, incremented when verified.
+	Note      string    // For admins, e.g. who the invite was sent to.
+	CreatedBy string    // Admin that created the invite.
+}
+
+// SignupState is the state of a signup request.
+type SignupState string
+
+const (
+	SignupUnverified SignupState = "unverified" // Contact email address not yet verified.
+	SignupPending    SignupState = "pending"    // Verified, waiting for approval by an admin.
+	SignupApproved   SignupState = "approved"   // Account was created.
+	SignupRejected   SignupState = "rejected"   // Rejected by an admin.
+)
+
+// SignupRequest is a request for a new account through self-service signup.
+// Unverified requests are removed after 24 hours.
+type SignupRequest struct {
+	ID           int64
+	Created      time.Time   `bstore:"nonzero,default now,index"`
+	State        SignupState `bstore:"nonzero,index"`
+	Account      string      `bstore:"nonzero"` // Name of the account to create.
+	Address      string      `bstore:"nonzero"` // Initial address of the account.
+	FullName     string
+	ContactEmail string `bstore:"nonzero"` // External address, verified before the account is created.
+	InviteID     int64  // Zero for open signups.
+	RemoteIP     string
+
+	VerifyTokenHash string    `bstore:"index" json:"-"` // Hex SHA-256 of the verification token, cleared when verified.
+	Verified        time.Time // When the contact email address was verified.
+	Decided         time.Time // When the request was approved or rejected.
+	DecidedBy       string    // Admin, or empty for automatic approval.
+	RejectReason    string
+
+	// Hashes of the password chosen at signup, set for the account when created.
+	// Cleared when the request is decided.
+	Password Password `json:"-"`
+}
+
+// ErrSignupToken is returned for unknown or expired signup invitation and
+// verification tokens.
+var ErrSignupToken = errors.New("unknown or expired token")
+
+// SignupToken returns a new random token for an invitation or verification, and
+// its hash for storing.
+func SignupToken() (token, hash string) {
+	buf := make([]byte, 18)
+	cryptorand.Read(buf)
+	token = base64.RawURLEncoding.EncodeToString(buf)
+	return token, SignupTokenHash(token)
+}
+
+// SignupTokenHash returns the hash of an invitation or verification token, as
+// stored.
+func SignupTokenHash(token string) string {
+	h := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// SignupInviteAdd adds an invitation for signups at domain, returning the token.
+// The token cannot be retrieved later.
+func SignupInviteAdd(ctx context.Context, domain string, maxUses int, expires time.Time, note, createdBy string) (token string, rerr error) {
+	if maxUses <= 0 {
+		return "", fmt.Errorf("max uses must be positive")
+	}
+	token, hash := SignupToken()
+	si := SignupInvite{Domain: domain, TokenHash: hash, Expires: expires, MaxUses: maxUses, Note: note, CreatedBy: createdBy}
+	if err := AuthDB.Insert(ctx, &si); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// SignupInviteList returns all invitations, most recent first.
+func SignupInviteList(ctx context.Context) ([]SignupInvite, error) {
+	return bstore.QueryDB[SignupInvite](ctx, AuthDB).SortDesc("ID").List()
+}
+
+// SignupInviteRemove removes an invitation. Pending requests that used the
+// invitation are not affected.
+func SignupInviteRemove(ctx context.Context, id int64) error {
+	return AuthDB.Delete(ctx, &SignupInvite{ID: id})
+}
+
+// SignupInviteGet returns the invitation for token if it can still be used.
+func SignupInviteGet(ctx context.Context, token string) (SignupInvite, error) {
+	q := bstore.QueryDB[SignupInvite](ctx, AuthDB)
+	q.FilterNonzero(SignupInvite{TokenHash: SignupTokenHash(token)})
+	si, err := q.Get()
+	if err == bstore.ErrAbsent || err == nil && (time.Until(si.Expires) < 0 || si.Uses >= si.MaxUses) {
+		return SignupInvite{}, ErrSignupToken
+	}
+	return si, err
+}
+
+// SignupRequestList returns signup requests, most recent first. If state is
+// non-empty, only requests in that state are returned.
+func SignupRequestList(ctx context.Context, state SignupState) ([]SignupRequest, error) {
+	q := bstore.QueryDB[SignupRequest](ctx, AuthDB)
+	if state != "" {
+		q.FilterNonzero(SignupRequest{State: state})
+	}
+	return q.SortDesc("ID").List()
+}
+
+// SignupCleanup removes unverified signup requests older than 24 hours, and
+// invitations that expired more than 30 days ago.
+func SignupCleanup(ctx context.Context) error {
+	q := bstore.QueryDB[SignupRequest](ctx, AuthDB)
+	q.FilterNonzero(SignupRequest{State: SignupUnverified})
+	q.FilterLess("Created", time.Now().Add(-24*time.Hour))
+	if _, err := q.Delete(); err != nil {
+		return fmt.Errorf("removing unverified signup requests: %v", err)
+	}
+
+	qi := bstore.QueryDB[SignupInvite](ctx, AuthDB)
+	qi.FilterLess("Expires", time.Now().Add(-30*24*time.Hour))
+	if _, err := qi.Delete(); err != nil {
+		return fmt.Errorf("removing expired signup invitations: %v", err)
+	}
+	return nil
+}
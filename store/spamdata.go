@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/mlog"
+)
+
+// SpamData holds the junk filter training data and sender lists of an account,
+// as exported for importing into another account, e.g. on another mox instance or
+// for an account with a new name, so spam filtering doesn't start from scratch.
+type SpamData struct {
+	Version    int       // Currently 1.
+	Exported   time.Time // Informational.
+	Account    string    // Exported account, informational.
+	Hams       uint32    // Number of messages trained as ham.
+	Spams      uint32    // Number of messages trained as spam.
+	Words      []junk.Wordscore
+	SenderList []SenderListEntry
+}
+
+// SpamDataExport returns the junk filter training data and sender lists of the
+// account. Without a configured junk filter, only the sender lists are exported.
+func (a *Account) SpamDataExport(ctx context.Context, log mlog.Log) (SpamData, error) {
+	sd := SpamData{Version: 1, Exported: time.Now(), Account: a.Name, Words: []junk.Wordscore{}}
+
+	var rerr error
+	a.WithRLock(func() {
+		jf, _, err := a.OpenJunkFilter(ctx, log)
+		if err != nil && !errors.Is(err, ErrNoJunkFilter) {
+			rerr = fmt.Errorf("open junk filter: %v", err)
+			return
+		} else if err == nil {
+			sd.Hams, sd.Spams, err = jf.Export(ctx, func(w junk.Wordscore) error {
+				sd.Words = append(sd.Words, w)
+				return nil
+			})
+			if err != nil {
+				xerr := jf.CloseDiscard()
+				log.Check(xerr, "closing junk filter after error")
+				rerr = fmt.Errorf("exporting junk filter: %v", err)
+				return
+			}
+			if err := jf.Close(); err != nil {
+				rerr = fmt.Errorf("closing junk filter: %v", err)
+				return
+			}
+		}
+
+		sd.SenderList, err = bstore.QueryDB[SenderListEntry](ctx, a.DB).SortAsc("Pattern").List()
+		if err != nil {
+			rerr = fmt.Errorf("listing sender lists: %v", err)
+		}
+	})
+	if rerr != nil {
+		return SpamData{}, rerr
+	}
+	return sd, nil
+}
+
+// SpamDataImport adds junk filter training data and sender lists from an export
+// to the account. Trained message and word counts are added to those of the
+// junk filter of the account, which must be configured if the export has
+// training data. Sender list entries are added, replacing the action of existing
+// entries with the same pattern.
+func (a *Account) SpamDataImport(ctx context.Context, log mlog.Log, sd SpamData) error {
+	if sd.Version != 1 {
+		return fmt.Errorf("unsupported version %d", sd.Version)
+	}
+	for i, e := range sd.SenderList {
+		switch e.Action {
+		case SenderListAllow, SenderListReject, SenderListDiscard:
+		default:
+			return fmt.Errorf("unknown sender list action %q for pattern %q", e.Action, e.Pattern)
+		}
+		p, err := SenderListPattern(e.Pattern)
+		if err != nil {
+			return fmt.Errorf("sender list pattern %q: %v", e.Pattern, err)
+		}
+		sd.SenderList[i].Pattern = p
+	}
+
+	var rerr error
+	a.WithWLock(func() {
+		if sd.Hams > 0 || sd.Spams > 0 || len(sd.Words) > 0 {
+			jf, _, err := a.OpenJunkFilter(ctx, log)
+			if err != nil {
+				rerr = fmt.Errorf("open junk filter: %w", err)
+				return
+			}
+			if err := jf.Import(ctx, sd.Hams, sd.Spams, sd.Words); err != nil {
+				xerr := jf.CloseDiscard()
+				log.Check(xerr, "closing junk filter after error")
+				rerr = fmt.Errorf("importing junk filter: %v", err)
+				return
+			}
+			if err := jf.Close(); err != nil {
+				rerr = fmt.Errorf("saving junk filter: %v", err)
+				return
+			}
+		}
+
+		err := a.DB.Write(ctx, func(tx *bstore.Tx) error {
+			for _, e := range sd.SenderList {
+				if _, err := senderListSave(tx, e.Pattern, e.Action); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			rerr = fmt.Errorf("importing sender lists: %v", err)
+		}
+	})
+	if rerr != nil {
+		return rerr
+	}
+
+	log.Info("spam data imported",
+		slog.String("account", a.Name),
+		slog.String("from", sd.Account),
+		slog.Any("hams", sd.Hams),
+		slog.Any("spams", sd.Spams),
+		slog.Int("words", len(sd.Words)),
+		slog.Int("senderlist", len(sd.SenderList)))
+	return nil
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestSpamData(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	jf, _, err := acc.OpenJunkFilter(ctxbg, log)
+	tcheck(t, err, "open junk filter")
+	err = jf.Train(ctxbg, true, map[string]struct{}{"hello": {}, "world": {}})
+	tcheck(t, err, "train ham")
+	err = jf.Train(ctxbg, false, map[string]struct{}{"money": {}})
+	tcheck(t, err, "train spam")
+	err = jf.Close()
+	tcheck(t, err, "close junk filter")
+	_, err = acc.SenderListSave(log, "friend@example.org", SenderListAllow)
+	tcheck(t, err, "save sender list entry")
+
+	sd, err := acc.SpamDataExport(ctxbg, log)
+	tcheck(t, err, "export")
+	tcompare(t, sd.Version, 1)
+	tcompare(t, []uint32{sd.Hams, sd.Spams}, []uint32{1, 1})
+	tcompare(t, sd.Words, []junk.Wordscore{{Word: "hello", Ham: 1}, {Word: "money", Spam: 1}, {Word: "world", Ham: 1}})
+	tcompare(t, len(sd.SenderList), 1)
+
+	// Start from scratch, as for a new account.
+	dir := filepath.Join(mox.DataDirPath("accounts"), acc.Name)
+	err = os.Remove(filepath.Join(dir, "junkfilter.db"))
+	tcheck(t, err, "remove junk filter database")
+	err = os.Remove(filepath.Join(dir, "junkfilter.bloom"))
+	tcheck(t, err, "remove junk filter bloom")
+	_, err = bstore.QueryDB[SenderListEntry](ctxbg, acc.DB).Delete()
+	tcheck(t, err, "remove sender list")
+
+	err = acc.SpamDataImport(ctxbg, log, sd)
+	tcheck(t, err, "import")
+	sd2, err := acc.SpamDataExport(ctxbg, log)
+	tcheck(t, err, "export")
+	tcompare(t, []uint32{sd2.Hams, sd2.Spams}, []uint32{1, 1})
+	tcompare(t, sd2.Words, sd.Words)
+	tcompare(t, len(sd2.SenderList), 1)
+	tcompare(t, sd2.SenderList[0].Pattern, "friend@example.org")
+	tcompare(t, sd2.SenderList[0].Action, SenderListAllow)
+
+	// Importing again adds the counts, and replaces sender list actions.
+	sd.SenderList[0].Action = SenderListReject
+	err = acc.SpamDataImport(ctxbg, log, sd)
+	tcheck(t, err, "import")
+	sd2, err = acc.SpamDataExport(ctxbg, log)
+	tcheck(t, err, "export")
+	tcompare(t, []uint32{sd2.Hams, sd2.Spams}, []uint32{2, 2})
+	tcompare(t, sd2.Words[0], junk.Wordscore{Word: "hello", Ham: 2})
+	tcompare(t, sd2.SenderList[0].Action, SenderListReject)
+
+	// Bad imports.
+	bad := sd
+	bad.Version = 2
+	err = acc.SpamDataImport(ctxbg, log, bad)
+	tcompare(t, err != nil, true)
+	bad = sd
+	bad.SenderList = []SenderListEntry{{Pattern: "example.org", Action: "bogus"}}
+	err = acc.SpamDataImport(ctxbg, log, bad)
+	tcompare(t, err != nil, true)
+
+	// Training data can only be imported into an account with junk filter.
+	accConf := mox.Conf.Dynamic.Accounts[acc.Name]
+	accConf.JunkFilter = nil
+	mox.Conf.Dynamic.Accounts[acc.Name] = accConf
+	err = acc.SpamDataImport(ctxbg, log, sd)
+	tcompare(t, errors.Is(err, ErrNoJunkFilter), true)
+	sd2, err = acc.SpamDataExport(ctxbg, log)
+	tcheck(t, err, "export without junk filter")
+	tcompare(t, len(sd2.Words), 0)
+	tcompare(t, len(sd2.SenderList), 1)
+}
@@ -244,6 +244,7 @@ func eraseMessages(log mlog.Log, acc *Account, ids []int64) {
 		}
 
 		if duchanged {
+			acc.quotaTrack(&du)
 			if err := tx.Update(&du); err != nil {
 				return fmt.Errorf("update disk usage after erasing: %v", err)
 			}
@@ -7,10 +7,12 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"sync"
 
 	"github.com/mjl-/bstore"
 
 	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/junk"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
@@ -50,6 +52,51 @@ func (a *Account) OpenJunkFilter(ctx context.Context, log mlog.Log) (*junk.Filte
 	return f, jf, err
 }
 
+// Shared junk filters are used by all accounts of a domain, access is serialized.
+var sharedJunkFilterLock sync.Mutex
+
+// WithSharedJunkFilter opens the shared junk filter for the domain, as configured
+// with SharedJunkFilter in the domain, and calls fn. Changes to the filter are saved
+// if fn returns nil. If the domain does not have a shared junk filter,
+// ErrNoJunkFilter is returned. An empty filter is initialized on first access.
+func WithSharedJunkFilter(ctx context.Context, log mlog.Log, domain dns.Domain, fn func(jf *junk.Filter, conf config.SharedJunkFilter) error) (rerr error) {
+	dom, ok := mox.Conf.Domain(domain)
+	if !ok || dom.SharedJunkFilter == nil {
+		return ErrNoJunkFilter
+	}
+	conf := *dom.SharedJunkFilter
+
+	sharedJunkFilterLock.Lock()
+	defer sharedJunkFilterLock.Unlock()
+
+	dir := mox.DataDirPath("junkfilter")
+	dbPath := filepath.Join(dir, domain.Name()+".db")
+	bloomPath := filepath.Join(dir, domain.Name()+".bloom")
+
+	var jf *junk.Filter
+	var err error
+	if _, xerr := os.Stat(dbPath); xerr != nil && os.IsNotExist(xerr) {
+		if err := os.MkdirAll(dir, 0770); err != nil {
+			return fmt.Errorf("creating directory for shared junk filter: %v", err)
+		}
+		jf, err = junk.NewFilter(ctx, log, conf.Params, dbPath, bloomPath)
+	} else {
+		jf, err = junk.OpenFilter(ctx, log, conf.Params, dbPath, bloomPath, false)
+	}
+	if err != nil {
+		return fmt.Errorf("open shared junk filter: %v", err)
+	}
+	defer func() {
+		if rerr != nil {
+			err := jf.CloseDiscard()
+			log.Check(err, "close shared junk filter without saving")
+		} else {
+			rerr = jf.Close()
+		}
+	}()
+	return fn(jf, conf)
+}
+
 func (a *Account) ensureJunkFilter(ctx context.Context, log mlog.Log, jfOpt *junk.Filter) (jf *junk.Filter, opened bool, err error) {
 	if jfOpt != nil {
 		return jfOpt, false, nil
@@ -62,13 +109,41 @@ func (a *Account) ensureJunkFilter(ctx context.Context, log mlog.Log, jfOpt *jun
 	return jf, true, nil
 }
 
+// SenderJunkMarked is called when a user changed the junk status of a message
+// delivered over SMTP, for updating the server-wide sender reputation. Set during
+// startup.
+var SenderJunkMarked func(ctx context.Context, log mlog.Log, m Message, junk bool)
+
+// SenderReputationUpdate calls SenderJunkMarked if the junk status of m, based on
+// its junk/notjunk flags, changed since it was last accounted for, and updates
+// m.ReputationJunk in the database.
+func SenderReputationUpdate(ctx context.Context, log mlog.Log, tx *bstore.Tx, m *Message) error {
+	if SenderJunkMarked == nil || m.Auth == nil || m.Junk == m.Notjunk || m.ReputationJunk != nil && *m.ReputationJunk == m.Junk {
+		return nil
+	}
+	SenderJunkMarked(ctx, log, *m, m.Junk)
+	isJunk := m.Junk
+	m.ReputationJunk = &isJunk
+	if err := tx.Update(m); err != nil {
+		return fmt.Errorf("updating message after updating sender reputation: %v", err)
+	}
+	return nil
+}
+
 // RetrainMessages (un)trains messages, if relevant given their flags. Updates
-// m.TrainedJunk after retraining.
+// m.TrainedJunk after retraining. The server-wide sender reputation is updated for
+// changed junk status.
 func (a *Account) RetrainMessages(ctx context.Context, log mlog.Log, tx *bstore.Tx, msgs []Message) (rerr error) {
 	if len(msgs) == 0 {
 		return nil
 	}
 
+	for i := range msgs {
+		if err := SenderReputationUpdate(ctx, log, tx, &msgs[i]); err != nil {
+			return err
+		}
+	}
+
 	var jf *junk.Filter
 
 	for i := range msgs {
@@ -150,6 +225,29 @@ func (a *Account) RetrainMessage(ctx context.Context, log mlog.Log, tx *bstore.T
 	if err := tx.Update(m); err != nil {
 		return err
 	}
+
+	// Also update the shared junk filter of the domain of the account, if any. The
+	// shared filter is secondary, so errors are only logged.
+	conf, _ := a.Conf()
+	err = WithSharedJunkFilter(ctx, log, conf.DNSDomain, func(sjf *junk.Filter, _ config.SharedJunkFilter) error {
+		words, err := sjf.ParseMessage(p)
+		if err != nil {
+			log.Infox("parsing message for updating shared junk filter", err)
+			return nil
+		}
+		if untrain {
+			if err := sjf.Untrain(ctx, !untrainJunk, words); err != nil {
+				return err
+			}
+		}
+		if train {
+			return sjf.Train(ctx, !trainJunk, words)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrNoJunkFilter) {
+		log.Errorx("updating shared junk filter", err)
+	}
 	return nil
 }
 
@@ -176,3 +274,112 @@ func (a *Account) TrainMessage(ctx context.Context, log mlog.Log, jf *junk.Filte
 
 	return true, jf.Train(ctx, ham, words)
 }
+
+// JunkFilterStats are training statistics for the junk filter of an account.
+type JunkFilterStats struct {
+	Account junk.Stats
+	Shared  *junk.Stats // Shared junk filter of the domain of the account, nil if not configured.
+}
+
+// JunkFilterStats returns statistics about the trained junk filter of the
+// account, and of the shared junk filter of its domain, if any.
+func (a *Account) JunkFilterStats(ctx context.Context, log mlog.Log) (JunkFilterStats, error) {
+	var stats JunkFilterStats
+
+	jf, _, err := a.OpenJunkFilter(ctx, log)
+	if err != nil {
+		return stats, err
+	}
+	stats.Account, err = jf.Stats(ctx)
+	xerr := jf.CloseDiscard()
+	log.Check(xerr, "closing junk filter")
+	if err != nil {
+		return stats, err
+	}
+
+	conf, _ := a.Conf()
+	err = WithSharedJunkFilter(ctx, log, conf.DNSDomain, func(sjf *junk.Filter, _ config.SharedJunkFilter) error {
+		s, err := sjf.Stats(ctx)
+		stats.Shared = &s
+		return err
+	})
+	if err != nil && !errors.Is(err, ErrNoJunkFilter) {
+		return stats, fmt.Errorf("shared junk filter: %v", err)
+	}
+	return stats, nil
+}
+
+// RetrainJunkFilter removes the junk filter of the account and trains a new junk
+// filter from scratch with all messages that have a junk or notjunk flag set,
+// updating m.TrainedJunk. The account is write-locked while retraining. The shared
+// junk filter of the domain is not changed.
+func (a *Account) RetrainJunkFilter(ctx context.Context, log mlog.Log) (total, trained int, rerr error) {
+	// todo: can we retrain an account without holding a write lock? perhaps by writing a junkfilter to a new location, and staying informed of message changes while we go through all messages in the account?
+
+	a.WithWLock(func() {
+		conf, _ := a.Conf()
+		if conf.JunkFilter == nil {
+			rerr = ErrNoJunkFilter
+			return
+		}
+
+		// Remove existing junk filter files.
+		basePath := mox.DataDirPath("accounts")
+		dbPath := filepath.Join(basePath, a.Name, "junkfilter.db")
+		bloomPath := filepath.Join(basePath, a.Name, "junkfilter.bloom")
+		err := os.Remove(dbPath)
+		log.Check(err, "removing old junkfilter database file", slog.String("path", dbPath))
+		err = os.Remove(bloomPath)
+		log.Check(err, "removing old junkfilter bloom filter file", slog.String("path", bloomPath))
+
+		// Open junk filter, this creates new files.
+		jf, _, err := a.OpenJunkFilter(ctx, log)
+		if err != nil {
+			rerr = fmt.Errorf("open new junk filter: %v", err)
+			return
+		}
+		defer func() {
+			if jf == nil {
+				return
+			}
+			err := jf.CloseDiscard()
+			log.Check(err, "closing junk filter during cleanup")
+		}()
+
+		// Read through messages with either junk or nonjunk flag set, and train them.
+		err = a.DB.Write(ctx, func(tx *bstore.Tx) error {
+			q := bstore.QueryTx[Message](tx)
+			q.FilterEqual("Expunged", false)
+			return q.ForEach(func(m Message) error {
+				total++
+				if m.Junk == m.Notjunk {
+					return nil
+				}
+				ok, err := a.TrainMessage(ctx, log, jf, m.Notjunk, m)
+				if ok {
+					trained++
+				}
+				if m.TrainedJunk == nil || *m.TrainedJunk != m.Junk {
+					m.TrainedJunk = &m.Junk
+					if err := tx.Update(&m); err != nil {
+						return fmt.Errorf("marking message as trained: %v", err)
+					}
+				}
+				return err
+			})
+		})
+		if err != nil {
+			rerr = fmt.Errorf("training messages: %v", err)
+			return
+		}
+		log.Info("retrained messages", slog.Int("total", total), slog.Int("trained", trained))
+
+		// Close junk filter, marking success.
+		err = jf.Close()
+		jf = nil
+		if err != nil {
+			rerr = fmt.Errorf("closing junk filter: %v", err)
+		}
+	})
+	return
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestJunkFilterTraining(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+
+	// Enable shared junk filter for the domain.
+	confDom := mox.Conf.Dynamic.Domains["mox.example"]
+	confDom.SharedJunkFilter = &config.SharedJunkFilter{Weight: 0.5, Params: junk.Params{Onegrams: true, MaxPower: 0.1, TopWords: 10}}
+	mox.Conf.Dynamic.Domains["mox.example"] = confDom
+
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+	conf, _ := acc.Conf()
+
+	// Moving to Junk marks as junk, moving out to a mailbox other than Trash marks as
+	// not junk.
+	inbox := Mailbox{Name: "Inbox"}
+	junkmb := Mailbox{Name: "Junk", SpecialUse: SpecialUse{Junk: true}}
+	trash := Mailbox{Name: "Trash", SpecialUse: SpecialUse{Trash: true}}
+	var fm Message
+	fm.JunkFlagsForMove(inbox, junkmb, conf)
+	tcompare(t, []bool{fm.Junk, fm.Notjunk}, []bool{true, false})
+	fm.JunkFlagsForMove(junkmb, trash, conf)
+	tcompare(t, []bool{fm.Junk, fm.Notjunk}, []bool{true, false})
+	fm.JunkFlagsForMove(junkmb, inbox, conf)
+	tcompare(t, []bool{fm.Junk, fm.Notjunk}, []bool{false, true})
+
+	msgFile, err := CreateMessageTemp(log, "train-test")
+	tcheck(t, err, "create temp message file")
+	defer CloseRemoveTempFile(log, msgFile, "temp message file")
+	msgWriter := message.NewWriter(msgFile)
+	_, err = msgWriter.Write([]byte("cheap pills for sale\r\n"))
+	tcheck(t, err, "write message")
+	msgPrefix := []byte("From: <remote@example.org>\r\nTo: <mjl@mox.example>\r\nSubject: offer\r\n\r\n")
+	m := Message{
+		Received:  time.Now(),
+		Size:      int64(len(msgPrefix)) + msgWriter.Size,
+		MsgPrefix: msgPrefix,
+	}
+	acc.WithWLock(func() {
+		err := acc.DeliverDestination(log, conf.Destinations["mjl@mox.example"], &m, msgFile)
+		tcheck(t, err, "deliver")
+	})
+
+	retrain := func(junk, notjunk bool) {
+		t.Helper()
+		m.Junk = junk
+		m.Notjunk = notjunk
+		acc.WithWLock(func() {
+			err := acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+				l := []Message{m}
+				err := acc.RetrainMessages(ctxbg, log, tx, l)
+				m = l[0]
+				return err
+			})
+			tcheck(t, err, "retrain")
+		})
+	}
+	checkStats := func(hams, spams uint32) {
+		t.Helper()
+		stats, err := acc.JunkFilterStats(ctxbg, log)
+		tcheck(t, err, "junk filter stats")
+		tcompare(t, []uint32{stats.Account.Hams, stats.Account.Spams}, []uint32{hams, spams})
+		if stats.Shared == nil {
+			t.Fatalf("missing stats for shared junk filter")
+		}
+		tcompare(t, []uint32{stats.Shared.Hams, stats.Shared.Spams}, []uint32{hams, spams})
+	}
+
+	// Both the account and the shared junk filter are trained.
+	retrain(true, false)
+	checkStats(0, 1)
+	retrain(false, true)
+	checkStats(1, 0)
+
+	// Retraining from scratch only changes the account junk filter.
+	total, trained, err := acc.RetrainJunkFilter(ctxbg, log)
+	tcheck(t, err, "retrain junk filter")
+	tcompare(t, []int{total, trained}, []int{1, 1})
+	checkStats(1, 0)
+
+	// Without shared junk filter for the domain.
+	confDom.SharedJunkFilter = nil
+	mox.Conf.Dynamic.Domains["mox.example"] = confDom
+	stats, err := acc.JunkFilterStats(ctxbg, log)
+	tcheck(t, err, "junk filter stats")
+	if stats.Shared != nil {
+		t.Fatalf("got stats for shared junk filter, expected none")
+	}
+}
@@ -0,0 +1,283 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/totp"
+)
+
+// TOTP is the time-based one-time password configuration of an account, for
+// two-factor authentication of web logins. An account has at most one. It is
+// only used after it has been confirmed with a code from the authenticator app.
+type TOTP struct {
+	ID        int64
+	Created   time.Time `bstore:"default now"`
+	Secret    []byte
+	Confirmed bool
+	LastStep  int64 // Time step of the last accepted code. Codes for this or earlier steps are rejected.
+}
+
+// AppPassword is a generated password for a single application, e.g. an IMAP/SMTP
+// client, for use instead of the account password when two-factor authentication
+// is enabled. Only valid for its protocols, and if IP ranges are set, only for
+// connections from those ranges. Only a hash is stored.
+type AppPassword struct {
+	ID        int64
+	Created   time.Time `bstore:"default now"`
+	Name      string    // Description, e.g. "Laptop".
+	Protocols []string  // Protocols this password can be used for, see AppPasswordProtocols.
+	IPRanges  []string  // If non-empty, networks in CIDR notation the password can be used from, e.g. 192.0.2.0/24 or 2001:db8::/32.
+	Hash      string    `json:"-"` // bcrypt.
+	LastUsed  time.Time
+	LastIP    string // Remote IP of last use.
+}
+
+// AppPasswordProtocols are the protocols an app password can be valid for. Web
+// interface logins always require the account password.
+var AppPasswordProtocols = []string{"imap", "submission", "webapi", "caldav", "carddav"}
+
+// TwoFactorRequired returns whether two-factor authentication is required for
+// the account by its configuration or the configuration of its domain.
+func TwoFactorRequired(accConf config.Account) bool {
+	if accConf.RequireTwoFactor {
+		return true
+	}
+	dom, ok := mox.Conf.Domain(accConf.DNSDomain)
+	return ok && dom.RequireTwoFactor
+}
+
+// TwoFactorEnabled returns whether the account has a confirmed TOTP
+// configuration.
+func (a *Account) TwoFactorEnabled() (bool, error) {
+	return bstore.QueryDB[TOTP](context.TODO(), a.DB).FilterNonzero(TOTP{Confirmed: true}).Exists()
+}
+
+// appPasswordRequired returns whether the account password can only be used for
+// web logins, and other protocols must use app passwords.
+func (a *Account) appPasswordRequired(accConf config.Account) (bool, error) {
+	if TwoFactorRequired(accConf) {
+		return true, nil
+	}
+	return a.TwoFactorEnabled()
+}
+
+// TOTPSetupStart returns a new secret for TOTP, replacing an earlier unconfirmed
+// secret. The TOTP configuration must be confirmed with TOTPSetupConfirm before
+// it is used. An error is returned if TOTP is already enabled.
+func (a *Account) TOTPSetupStart(log mlog.Log) (secret []byte, rerr error) {
+	secret = totp.NewSecret()
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		if exists, err := bstore.QueryTx[TOTP](tx).FilterNonzero(TOTP{Confirmed: true}).Exists(); err != nil {
+			return fmt.Errorf("checking for existing totp: %v", err)
+		} else if exists {
+			return fmt.Errorf("two-factor authentication already enabled")
+		}
+		if _, err := bstore.QueryTx[TOTP](tx).Delete(); err != nil {
+			return fmt.Errorf("removing unconfirmed totp: %v", err)
+		}
+		return tx.Insert(&TOTP{Secret: secret})
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Info("totp setup started for account", slog.String("account", a.Name))
+	return secret, nil
+}
+
+// TOTPSetupConfirm enables TOTP started with TOTPSetupStart after verifying code
+// from the authenticator app. ErrUnknownCredentials is returned for a bad code.
+func (a *Account) TOTPSetupConfirm(log mlog.Log, code string) error {
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		t, err := bstore.QueryTx[TOTP](tx).FilterEqual("Confirmed", false).Get()
+		if err == bstore.ErrAbsent {
+			return fmt.Errorf("no two-factor authentication setup in progress")
+		} else if err != nil {
+			return fmt.Errorf("looking up totp: %v", err)
+		}
+		step, ok := totp.Verify(t.Secret, code, time.Now(), t.LastStep)
+		if !ok {
+			return ErrUnknownCredentials
+		}
+		t.Confirmed = true
+		t.LastStep = step
+		return tx.Update(&t)
+	})
+	if err == nil {
+		log.Info("totp enabled for account", slog.String("account", a.Name))
+	}
+	return err
+}
+
+// TOTPVerify checks code for a web login against the confirmed TOTP of the
+// account. ErrUnknownCredentials is returned for a bad or reused code.
+func (a *Account) TOTPVerify(code string) error {
+	return a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		t, err := bstore.QueryTx[TOTP](tx).FilterNonzero(TOTP{Confirmed: true}).Get()
+		if err == bstore.ErrAbsent {
+			return fmt.Errorf("two-factor authentication not enabled")
+		} else if err != nil {
+			return fmt.Errorf("looking up totp: %v", err)
+		}
+		step, ok := totp.Verify(t.Secret, code, time.Now(), t.LastStep)
+		if !ok {
+			return ErrUnknownCredentials
+		}
+		t.LastStep = step
+		return tx.Update(&t)
+	})
+}
+
+// TOTPRemove disables two-factor authentication for the account, e.g. when the
+// authenticator app is lost. App passwords are kept.
+func (a *Account) TOTPRemove(log mlog.Log) error {
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		_, err := bstore.QueryTx[TOTP](tx).Delete()
+		return err
+	})
+	if err == nil {
+		log.Info("totp removed for account", slog.String("account", a.Name))
+	}
+	return err
+}
+
+// AppPasswordAdd adds a new app password valid for protocols, returning the
+// password in plain text. It cannot be retrieved later. If ipRanges is non-empty,
+// the password is only valid for connections from those networks. Single IPs are
+// accepted as ranges too.
+func (a *Account) AppPasswordAdd(log mlog.Log, name string, protocols, ipRanges []string) (AppPassword, string, error) {
+	if name == "" {
+		return AppPassword{}, "", fmt.Errorf("name required")
+	}
+	if len(protocols) == 0 {
+		return AppPassword{}, "", fmt.Errorf("at least one protocol required")
+	}
+	for _, p := range protocols {
+		if !slices.Contains(AppPasswordProtocols, p) {
+			return AppPassword{}, "", fmt.Errorf("unknown protocol %q", p)
+		}
+	}
+
+	var ranges []string
+	for _, r := range ipRanges {
+		ipnet, err := parseIPRange(r)
+		if err != nil {
+			return AppPassword{}, "", err
+		}
+		ranges = append(ranges, ipnet.String())
+	}
+
+	password := randomCode()
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return AppPassword{}, "", fmt.Errorf("generating password hash: %w", err)
+	}
+	ap := AppPassword{Name: name, Protocols: protocols, IPRanges: ranges, Hash: string(hash)}
+	err = a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		return tx.Insert(&ap)
+	})
+	if err != nil {
+		return AppPassword{}, "", fmt.Errorf("inserting app password: %v", err)
+	}
+	log.Info("app password added for account", slog.String("account", a.Name), slog.String("name", name), slog.Any("protocols", protocols), slog.Any("ipranges", ranges))
+	return ap, password, nil
+}
+
+// parseIPRange parses a network in CIDR notation, or a single IP.
+func parseIPRange(s string) (*net.IPNet, error) {
+	if ip := net.ParseIP(s); ip != nil {
+		bits := 128
+		if ip.To4() != nil {
+			ip = ip.To4()
+			bits = 32
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}, nil
+	}
+	_, ipnet, err := net.ParseCIDR(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing ip range %q: %v", s, err)
+	}
+	return ipnet, nil
+}
+
+// ipAllowed returns whether ip is in one of the IP ranges of the app password.
+func (ap AppPassword) ipAllowed(ip net.IP) bool {
+	if len(ap.IPRanges) == 0 {
+		return true
+	}
+	if ip == nil {
+		return false
+	}
+	for _, r := range ap.IPRanges {
+		if ipnet, err := parseIPRange(r); err == nil && ipnet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// AppPasswordRemove removes an app password.
+func (a *Account) AppPasswordRemove(log mlog.Log, id int64) error {
+	err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+		return tx.Delete(&AppPassword{ID: id})
+	})
+	if err == bstore.ErrAbsent {
+		return fmt.Errorf("app password not found")
+	} else if err != nil {
+		return err
+	}
+	log.Info("app password removed for account", slog.String("account", a.Name), slog.Int64("id", id))
+	return nil
+}
+
+// appPasswordMatch returns whether password is an app password of the account
+// valid for protocol and remoteIP, updating its last use.
+func (a *Account) appPasswordMatch(email, password, protocol string, remoteIP net.IP) (bool, error) {
+	aps, err := bstore.QueryDB[AppPassword](context.TODO(), a.DB).List()
+	if err != nil {
+		return false, fmt.Errorf("listing app passwords: %v", err)
+	}
+	for _, ap := range aps {
+		if !slices.Contains(ap.Protocols, protocol) || !ap.ipAllowed(remoteIP) {
+			continue
+		}
+		authCache.Lock()
+		ok := authCache.success[authKey{email, ap.Hash}] == password
+		authCache.Unlock()
+		if !ok && bcrypt.CompareHashAndPassword([]byte(ap.Hash), []byte(password)) != nil {
+			continue
+		}
+		authCache.Lock()
+		authCache.success[authKey{email, ap.Hash}] = password
+		authCache.Unlock()
+
+		// Only update once in a while, not for every login.
+		var ipStr string
+		if remoteIP != nil {
+			ipStr = remoteIP.String()
+		}
+		if time.Since(ap.LastUsed) > time.Hour || ap.LastIP != ipStr {
+			ap.LastUsed = time.Now()
+			ap.LastIP = ipStr
+			err := a.DB.Write(context.TODO(), func(tx *bstore.Tx) error {
+				return tx.Update(&ap)
+			})
+			if err != nil && err != bstore.ErrAbsent {
+				return false, fmt.Errorf("updating app password last use: %v", err)
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
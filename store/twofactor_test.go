@@ -0,0 +1,159 @@
+package store
+
+import (
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/totp"
+)
+
+func TestTwoFactor(t *testing.T) {
+	log := mlog.New("store", nil)
+	os.RemoveAll("../testdata/store/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/store/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := Init(ctxbg)
+	tcheck(t, err, "init")
+	defer func() {
+		err := Close()
+		tcheck(t, err, "close")
+	}()
+	defer Switchboard()()
+	acc, err := OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	err = acc.SetPassword(log, "testtest")
+	tcheck(t, err, "set password")
+
+	// App password works, also without two-factor authentication, but only for its protocols.
+	_, apppw, err := acc.AppPasswordAdd(log, "laptop", []string{"imap"}, nil)
+	tcheck(t, err, "add app password")
+	_, _, err = acc.AppPasswordAdd(log, "bogus", []string{"pop3"}, nil)
+	if err == nil {
+		t.Fatalf("app password with unknown protocol accepted")
+	}
+	_, _, err = acc.AppPasswordAdd(log, "bogus", []string{"imap"}, []string{"192.0.2.0/33"})
+	if err == nil {
+		t.Fatalf("app password with bad ip range accepted")
+	}
+	var remoteIP net.IP
+	openAuth := func(password, protocol string) error {
+		t.Helper()
+		a, _, err := OpenEmailAuth(log, "mjl@mox.example", password, protocol, remoteIP, false)
+		if err == nil {
+			err = a.Close()
+			tcheck(t, err, "close account")
+		}
+		return err
+	}
+	tcheck(t, openAuth(apppw, "imap"), "login with app password")
+	tcheck(t, openAuth("testtest", "imap"), "login with account password")
+	if err := openAuth(apppw, "submission"); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for other protocol", err)
+	}
+	if err := openAuth(apppw, ""); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for web login with app password", err)
+	}
+
+	// App password restricted to IP ranges.
+	ap, apppw2, err := acc.AppPasswordAdd(log, "office", []string{"imap"}, []string{"192.0.2.0/24", "2001:db8::1"})
+	tcheck(t, err, "add app password with ip ranges")
+	tcompare(t, ap.IPRanges, []string{"192.0.2.0/24", "2001:db8::1/128"})
+	if err := openAuth(apppw2, "imap"); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials without remote ip", err)
+	}
+	remoteIP = net.ParseIP("198.51.100.1")
+	if err := openAuth(apppw2, "imap"); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for ip outside ranges", err)
+	}
+	for _, ip := range []string{"192.0.2.10", "2001:db8::1"} {
+		remoteIP = net.ParseIP(ip)
+		tcheck(t, openAuth(apppw2, "imap"), "login with app password from allowed ip")
+	}
+	ap, err = bstore.QueryDB[AppPassword](ctxbg, acc.DB).FilterID(ap.ID).Get()
+	tcheck(t, err, "get app password")
+	tcompare(t, ap.LastIP, "2001:db8::1")
+	if ap.LastUsed.IsZero() {
+		t.Fatalf("last use not set")
+	}
+	remoteIP = nil
+	err = acc.AppPasswordRemove(log, ap.ID)
+	tcheck(t, err, "remove app password")
+	if err := acc.AppPasswordRemove(log, ap.ID); err == nil {
+		t.Fatalf("removing absent app password succeeded")
+	}
+
+	// Setup must be confirmed with a valid code.
+	secret, err := acc.TOTPSetupStart(log)
+	tcheck(t, err, "totp setup start")
+	enabled, err := acc.TwoFactorEnabled()
+	tcheck(t, err, "two-factor enabled")
+	tcompare(t, enabled, false)
+	if err := acc.TOTPSetupConfirm(log, "000000x"); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for bad code", err)
+	}
+	step := totp.Step(time.Now())
+	err = acc.TOTPSetupConfirm(log, totp.Code(secret, step))
+	tcheck(t, err, "totp setup confirm")
+	enabled, err = acc.TwoFactorEnabled()
+	tcheck(t, err, "two-factor enabled")
+	tcompare(t, enabled, true)
+	if _, err := acc.TOTPSetupStart(log); err == nil {
+		t.Fatalf("setup started while enabled")
+	}
+
+	// Code used for confirmation cannot be used again.
+	if err := acc.TOTPVerify(totp.Code(secret, step)); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for reused code", err)
+	}
+	tcheck(t, acc.TOTPVerify(totp.Code(secret, step+1)), "verify next code")
+
+	// Account password is now only for web logins.
+	if err := openAuth("testtest", "imap"); !errors.Is(err, ErrAppPassword) {
+		t.Fatalf("got err %v, expected ErrAppPassword", err)
+	}
+	tcheck(t, openAuth("testtest", ""), "web login with account password")
+	tcheck(t, openAuth(apppw, "imap"), "login with app password")
+	if err := acc.CheckPasswordLogin(); !errors.Is(err, ErrAppPassword) {
+		t.Fatalf("got err %v, expected ErrAppPassword", err)
+	}
+
+	err = acc.TOTPRemove(log)
+	tcheck(t, err, "totp remove")
+	tcheck(t, openAuth("testtest", "imap"), "login with account password after disabling")
+	tcheck(t, acc.CheckPasswordLogin(), "check password login")
+
+	// Requiring two-factor authentication also requires app passwords.
+	accConf, _ := acc.Conf()
+	accConf.RequireTwoFactor = true
+	mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	defer func() {
+		accConf.RequireTwoFactor = false
+		mox.Conf.Dynamic.Accounts["mjl"] = accConf
+	}()
+	if err := openAuth("testtest", "imap"); !errors.Is(err, ErrAppPassword) {
+		t.Fatalf("got err %v, expected ErrAppPassword when required", err)
+	}
+
+	aps, err := bstore.QueryDB[AppPassword](ctxbg, acc.DB).List()
+	tcheck(t, err, "list app passwords")
+	tcompare(t, len(aps), 1)
+	err = acc.AppPasswordRemove(log, aps[0].ID)
+	tcheck(t, err, "remove app password")
+	if err := openAuth(apppw, "imap"); !errors.Is(err, ErrUnknownCredentials) {
+		t.Fatalf("got err %v, expected ErrUnknownCredentials for removed app password", err)
+	}
+}
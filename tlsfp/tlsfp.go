@@ -0,0 +1,349 @@
+// Package tlsfp computes JA3 and JA4 fingerprints of TLS clients.
+//
+// A fingerprint is derived from the parameters in the ClientHello message a TLS
+// client sends at the start of a connection: versions, cipher suites,
+// extensions, elliptic curves, signature algorithms. The fingerprint identifies
+// the TLS implementation and its configuration, not the client host. Abusive
+// software (e.g. a botnet) may rotate IP addresses, but often keeps using the
+// same TLS stack.
+//
+// JA3 is the MD5 hash of the decimal parameters in ClientHello order. JA4 is a
+// human-readable prefix followed by two truncated SHA256 hashes, of sorted cipher
+// suites and sorted extensions, making it resistant to extension order
+// randomization. GREASE values are ignored for both. See
+// https://github.com/salesforce/ja3 and https://github.com/FoxIO-LLC/ja4.
+package tlsfp
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrNotClientHello = errors.New("not a tls clienthello")
+	ErrTruncated      = errors.New("truncated tls clienthello")
+)
+
+// Extension types used in fingerprints.
+const (
+	extServerName          uint16 = 0
+	extSupportedGroups     uint16 = 10
+	extECPointFormats      uint16 = 11
+	extSignatureAlgorithms uint16 = 13
+	extALPN                uint16 = 16
+	extSupportedVersions   uint16 = 43
+)
+
+// ClientHello holds the parameters of a TLS ClientHello used for fingerprinting.
+// GREASE values are included as sent by the client.
+type ClientHello struct {
+	Version             uint16 // Legacy version field, 0x0303 for TLS 1.2 and 1.3.
+	CipherSuites        []uint16
+	Extensions          []uint16 // In order of ClientHello.
+	SupportedGroups     []uint16 // Elliptic curves.
+	ECPointFormats      []uint8
+	SignatureAlgorithms []uint16
+	SupportedVersions   []uint16
+	ALPN                []string
+	ServerName          bool // Whether an SNI extension was present.
+}
+
+// isGREASE returns whether v is a reserved value that clients add to prevent
+// ossification, see RFC 8701.
+func isGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func withoutGREASE(l []uint16) []uint16 {
+	var r []uint16
+	for _, v := range l {
+		if !isGREASE(v) {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+type parser struct {
+	buf []byte
+	err error
+}
+
+func (p *parser) bytes(n int) []byte {
+	if p.err != nil || n > len(p.buf) {
+		p.err = ErrTruncated
+		return nil
+	}
+	r := p.buf[:n]
+	p.buf = p.buf[n:]
+	return r
+}
+
+func (p *parser) uint8() uint8 {
+	b := p.bytes(1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (p *parser) uint16() uint16 {
+	b := p.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint16(b)
+}
+
+func (p *parser) uint24() int {
+	b := p.bytes(3)
+	if b == nil {
+		return 0
+	}
+	return int(b[0])<<16 | int(b[1])<<8 | int(b[2])
+}
+
+// uint16s parses a vector of uint16 values, with a length prefix of lenSize bytes.
+func (p *parser) uint16s(lenSize int) []uint16 {
+	var n int
+	if lenSize == 1 {
+		n = int(p.uint8())
+	} else {
+		n = int(p.uint16())
+	}
+	b := p.bytes(n)
+	var l []uint16
+	for len(b) >= 2 {
+		l = append(l, binary.BigEndian.Uint16(b))
+		b = b[2:]
+	}
+	return l
+}
+
+// Parse parses a TLS record holding a ClientHello handshake message. If the
+// ClientHello was split over multiple records, only the first is parsed and
+// ErrTruncated is returned.
+func Parse(record []byte) (ClientHello, error) {
+	var h ClientHello
+
+	// Record header.
+	p := &parser{buf: record}
+	if typ := p.uint8(); p.err == nil && typ != 22 {
+		return h, ErrNotClientHello
+	}
+	p.uint16() // Record version, not used.
+	p.buf = p.bytes(int(p.uint16()))
+
+	// Handshake header.
+	if typ := p.uint8(); p.err == nil && typ != 1 {
+		return h, ErrNotClientHello
+	}
+	p.buf = p.bytes(p.uint24())
+
+	h.Version = p.uint16()
+	p.bytes(32)             // Random.
+	p.bytes(int(p.uint8())) // Legacy session id.
+	h.CipherSuites = p.uint16s(2)
+	p.bytes(int(p.uint8())) // Legacy compression methods.
+	if p.err != nil {
+		return h, p.err
+	}
+	if len(p.buf) == 0 {
+		// No extensions, e.g. old SSLv3 clients.
+		return h, nil
+	}
+
+	exts := &parser{buf: p.bytes(int(p.uint16()))}
+	for p.err == nil && exts.err == nil && len(exts.buf) > 0 {
+		typ := exts.uint16()
+		data := &parser{buf: exts.bytes(int(exts.uint16()))}
+		if exts.err != nil {
+			break
+		}
+		h.Extensions = append(h.Extensions, typ)
+		switch typ {
+		case extServerName:
+			h.ServerName = true
+		case extSupportedGroups:
+			h.SupportedGroups = data.uint16s(2)
+		case extECPointFormats:
+			h.ECPointFormats = data.bytes(int(data.uint8()))
+		case extSignatureAlgorithms:
+			h.SignatureAlgorithms = data.uint16s(2)
+		case extSupportedVersions:
+			h.SupportedVersions = data.uint16s(1)
+		case extALPN:
+			l := &parser{buf: data.bytes(int(data.uint16()))}
+			for l.err == nil && len(l.buf) > 0 {
+				if proto := l.bytes(int(l.uint8())); l.err == nil {
+					h.ALPN = append(h.ALPN, string(proto))
+				}
+			}
+			data.err = l.err
+		}
+		if data.err != nil {
+			return h, fmt.Errorf("extension %d: %w", typ, data.err)
+		}
+	}
+	if p.err != nil {
+		return h, p.err
+	}
+	return h, exts.err
+}
+
+func joinInts[T uint8 | uint16](l []T) string {
+	s := make([]string, len(l))
+	for i, v := range l {
+		s[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(s, "-")
+}
+
+// JA3String returns the JA3 input string: the decimal TLS version, cipher suites,
+// extensions, elliptic curves and point formats.
+func (h ClientHello) JA3String() string {
+	return strings.Join([]string{
+		strconv.Itoa(int(h.Version)),
+		joinInts(withoutGREASE(h.CipherSuites)),
+		joinInts(withoutGREASE(h.Extensions)),
+		joinInts(withoutGREASE(h.SupportedGroups)),
+		joinInts(h.ECPointFormats),
+	}, ",")
+}
+
+// JA3 returns the JA3 fingerprint, the hex-encoded MD5 hash of JA3String.
+func (h ClientHello) JA3() string {
+	sum := md5.Sum([]byte(h.JA3String()))
+	return hex.EncodeToString(sum[:])
+}
+
+// JA4 returns the JA4 fingerprint for a TLS connection over TCP, e.g.
+// "t13d1516h2_8daaf6152771_e5627efa2ab1".
+func (h ClientHello) JA4() string {
+	version := h.Version
+	if l := withoutGREASE(h.SupportedVersions); len(l) > 0 {
+		version = slices.Max(l)
+	}
+	var v string
+	switch version {
+	case 0x0304:
+		v = "13"
+	case 0x0303:
+		v = "12"
+	case 0x0302:
+		v = "11"
+	case 0x0301:
+		v = "10"
+	case 0x0300:
+		v = "s3"
+	default:
+		v = "00"
+	}
+
+	sni := "i"
+	if h.ServerName {
+		sni = "d"
+	}
+
+	alpn := "00"
+	if len(h.ALPN) > 0 && h.ALPN[0] != "" {
+		s := h.ALPN[0]
+		alnum := func(c byte) bool {
+			return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+		}
+		if alnum(s[0]) && alnum(s[len(s)-1]) {
+			alpn = s[:1] + s[len(s)-1:]
+		} else {
+			x := hex.EncodeToString([]byte(s))
+			alpn = x[:1] + x[len(x)-1:]
+		}
+	}
+
+	ciphers := withoutGREASE(h.CipherSuites)
+	exts := withoutGREASE(h.Extensions)
+	a := fmt.Sprintf("t%s%s%02d%02d%s", v, sni, min(len(ciphers), 99), min(len(exts), 99), alpn)
+
+	hexList := func(l []uint16) string {
+		s := make([]string, len(l))
+		for i, v := range l {
+			s[i] = fmt.Sprintf("%04x", v)
+		}
+		return strings.Join(s, ",")
+	}
+	hash := func(s string) string {
+		if s == "" {
+			return "000000000000"
+		}
+		sum := sha256.Sum256([]byte(s))
+		return hex.EncodeToString(sum[:])[:12]
+	}
+
+	ciphers = slices.Clone(ciphers)
+	slices.Sort(ciphers)
+	b := hash(hexList(ciphers))
+
+	// SNI and ALPN are already represented in the first part.
+	var sortedExts []uint16
+	for _, e := range exts {
+		if e != extServerName && e != extALPN {
+			sortedExts = append(sortedExts, e)
+		}
+	}
+	slices.Sort(sortedExts)
+	c := hexList(sortedExts)
+	if c != "" && len(h.SignatureAlgorithms) > 0 {
+		c += "_" + hexList(withoutGREASE(h.SignatureAlgorithms))
+	}
+	return a + "_" + b + "_" + hash(c)
+}
+
+// Valid returns whether s is a syntactically valid JA3 (32 lower case hexadecimal
+// characters) or JA4 fingerprint.
+func Valid(s string) bool {
+	ishex := func(s string) bool {
+		_, err := hex.DecodeString(s)
+		return err == nil && s == strings.ToLower(s)
+	}
+	if len(s) == 32 {
+		return ishex(s)
+	}
+	t := strings.Split(s, "_")
+	return len(t) == 3 && len(t[0]) == 10 && t[0] == strings.ToLower(t[0]) && len(t[1]) == 12 && ishex(t[1]) && len(t[2]) == 12 && ishex(t[2])
+}
+
+// Conn wraps a net.Conn and keeps a copy of the first TLS record read from it,
+// for use on the server side of a TLS connection, where it holds the ClientHello.
+type Conn struct {
+	net.Conn
+
+	buf  []byte
+	done bool
+}
+
+func (c *Conn) Read(buf []byte) (int, error) {
+	n, err := c.Conn.Read(buf)
+	if n > 0 && !c.done {
+		c.buf = append(c.buf, buf[:n]...)
+		if len(c.buf) >= 5 {
+			if need := 5 + int(binary.BigEndian.Uint16(c.buf[3:5])); len(c.buf) >= need {
+				c.buf = c.buf[:need]
+				c.done = true
+			}
+		}
+	}
+	return n, err
+}
+
+// ClientHello parses the ClientHello from the first record read from the
+// connection.
+func (c *Conn) ClientHello() (ClientHello, error) {
+	return Parse(c.buf)
+}
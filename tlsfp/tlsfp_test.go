@@ -0,0 +1,187 @@
+package tlsfp
+
+import (
+	"crypto/ed25519"
+	cryptorand "crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"net"
+	"slices"
+	"testing"
+	"time"
+)
+
+func tcheckf(t *testing.T, err error, format string, args ...any) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", fmt.Sprintf(format, args...), err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+// makeHello returns a TLS record with a ClientHello with the parameters of h.
+func makeHello(h ClientHello) []byte {
+	u16 := func(v uint16) []byte {
+		return binary.BigEndian.AppendUint16(nil, v)
+	}
+	u16s := func(l []uint16) []byte {
+		var b []byte
+		for _, v := range l {
+			b = append(b, u16(v)...)
+		}
+		return b
+	}
+
+	var exts []byte
+	for _, e := range h.Extensions {
+		var data []byte
+		switch e {
+		case extServerName:
+			name := []byte("mox.example")
+			entry := append([]byte{0}, append(u16(uint16(len(name))), name...)...)
+			data = append(u16(uint16(len(entry))), entry...)
+		case extSupportedGroups:
+			data = append(u16(uint16(2*len(h.SupportedGroups))), u16s(h.SupportedGroups)...)
+		case extECPointFormats:
+			data = append([]byte{byte(len(h.ECPointFormats))}, h.ECPointFormats...)
+		case extSignatureAlgorithms:
+			data = append(u16(uint16(2*len(h.SignatureAlgorithms))), u16s(h.SignatureAlgorithms)...)
+		case extSupportedVersions:
+			data = append([]byte{byte(2 * len(h.SupportedVersions))}, u16s(h.SupportedVersions)...)
+		case extALPN:
+			var l []byte
+			for _, p := range h.ALPN {
+				l = append(append(l, byte(len(p))), p...)
+			}
+			data = append(u16(uint16(len(l))), l...)
+		}
+		exts = append(exts, u16(e)...)
+		exts = append(exts, u16(uint16(len(data)))...)
+		exts = append(exts, data...)
+	}
+
+	body := u16(h.Version)
+	body = append(body, make([]byte, 32)...) // Random.
+	body = append(body, 0)                   // Session id.
+	body = append(body, u16(uint16(2*len(h.CipherSuites)))...)
+	body = append(body, u16s(h.CipherSuites)...)
+	body = append(body, 1, 0) // Compression methods.
+	body = append(body, u16(uint16(len(exts)))...)
+	body = append(body, exts...)
+
+	hs := append([]byte{1, 0}, u16(uint16(len(body)))...)
+	hs = append(hs, body...)
+	rec := append([]byte{22, 3, 1}, u16(uint16(len(hs)))...)
+	return append(rec, hs...)
+}
+
+func TestFingerprint(t *testing.T) {
+	// Parameters of a Chrome ClientHello, with GREASE values, from the JA4 documentation.
+	h := ClientHello{
+		Version:             0x0303,
+		CipherSuites:        []uint16{0x2a2a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+		Extensions:          []uint16{0x3a3a, 0x0000, 0x0017, 0xff01, 0x000a, 0x000b, 0x0023, 0x0010, 0x0005, 0x000d, 0x0012, 0x0033, 0x002d, 0x002b, 0x001b, 0x4469, 0x0015},
+		SupportedGroups:     []uint16{0x4a4a, 0x001d, 0x0017, 0x0018},
+		ECPointFormats:      []uint8{0},
+		SignatureAlgorithms: []uint16{0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601},
+		SupportedVersions:   []uint16{0x5a5a, 0x0304, 0x0303},
+		ALPN:                []string{"h2", "http/1.1"},
+		ServerName:          true,
+	}
+	rec := makeHello(h)
+	ph, err := Parse(rec)
+	tcheckf(t, err, "parse clienthello")
+	tcompare(t, ph, h)
+	tcompare(t, ph.JA4(), "t13d1516h2_8daaf6152771_e5627efa2ab1")
+	tcompare(t, ph.JA3String(), "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0")
+	tcompare(t, Valid(ph.JA3()), true)
+	tcompare(t, Valid(ph.JA4()), true)
+
+	// Without SNI, ALPN and supported versions.
+	h2 := ClientHello{
+		Version:             0x0303,
+		CipherSuites:        []uint16{0xc02f},
+		Extensions:          []uint16{0x000d},
+		SignatureAlgorithms: []uint16{0x0401},
+	}
+	ph, err = Parse(makeHello(h2))
+	tcheckf(t, err, "parse clienthello")
+	tcompare(t, ph.JA4()[:10], "t12i010100")
+
+	_, err = Parse(rec[:len(rec)-1])
+	if err == nil {
+		t.Fatalf("parsing truncated clienthello succeeded")
+	}
+	_, err = Parse([]byte("EHLO localhost\r\n"))
+	tcompare(t, err, ErrNotClientHello)
+
+	tcompare(t, Valid("6734f37431670b3ab4292b8f60f29984"), true)
+	tcompare(t, Valid("6734F37431670B3AB4292B8F60F29984"), false)
+	tcompare(t, Valid("t13d1516h2_8daaf6152771"), false)
+	tcompare(t, Valid("t13d1516h2_8daaf6152771_e5627efa2ab1x"), false)
+}
+
+// Fingerprint a ClientHello from crypto/tls, compare with what the server sees.
+func TestConn(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(cryptorand.Reader)
+	tcheckf(t, err, "generate key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		DNSNames:     []string{"mox.example"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certBuf, err := x509.CreateCertificate(cryptorand.Reader, template, template, pub, priv)
+	tcheckf(t, err, "create certificate")
+
+	var info *tls.ClientHelloInfo
+	serverConfig := &tls.Config{
+		Certificates:           []tls.Certificate{{Certificate: [][]byte{certBuf}, PrivateKey: priv}},
+		SessionTicketsDisabled: true,
+		GetConfigForClient: func(chi *tls.ClientHelloInfo) (*tls.Config, error) {
+			info = chi
+			return nil, nil
+		},
+	}
+	clientConfig := &tls.Config{ServerName: "mox.example", InsecureSkipVerify: true, NextProtos: []string{"smtp"}}
+
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	go func() {
+		tls.Client(clientConn, clientConfig).Handshake()
+	}()
+	fpConn := &Conn{Conn: serverConn}
+	tlsConn := tls.Server(fpConn, serverConfig)
+	defer serverConn.Close()
+	err = tlsConn.Handshake()
+	tcheckf(t, err, "handshake")
+
+	h, err := fpConn.ClientHello()
+	tcheckf(t, err, "parse clienthello")
+	tcompare(t, h.CipherSuites, info.CipherSuites)
+	tcompare(t, h.SupportedVersions, info.SupportedVersions)
+	tcompare(t, h.ECPointFormats, info.SupportedPoints)
+	tcompare(t, h.ALPN, info.SupportedProtos)
+	tcompare(t, h.ServerName, true)
+	curves := make([]uint16, len(info.SupportedCurves))
+	for i, c := range info.SupportedCurves {
+		curves[i] = uint16(c)
+	}
+	tcompare(t, h.SupportedGroups, curves)
+	// Go sends signature_algorithms_cert in addition, info has that combined list.
+	for _, s := range h.SignatureAlgorithms {
+		if !slices.Contains(info.SignatureSchemes, tls.SignatureScheme(s)) {
+			t.Fatalf("signature scheme %x not in %v", s, info.SignatureSchemes)
+		}
+	}
+	tcompare(t, h.JA4()[:10], "t13d"+fmt.Sprintf("%02d%02d", len(info.CipherSuites), len(h.Extensions))+"sp")
+}
@@ -15,7 +15,7 @@ import (
 )
 
 var (
-	ReportDBTypes = []any{Record{}}
+	ReportDBTypes = []any{Record{}, FailureType{}}
 	ReportDB      *bstore.DB
 
 	// Accessed directly by tlsrptsend.
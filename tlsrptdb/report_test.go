@@ -333,3 +333,80 @@ func TestReport(t *testing.T) {
 		t.Errorf("adding report with all unknown domains, expected error")
 	}
 }
+
+func TestStats(t *testing.T) {
+	mox.Context = ctxbg
+	mox.Shutdown, mox.ShutdownCancel = context.WithCancel(ctxbg)
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/tlsrpt/fake.conf")
+	mox.Conf.Static.HostnameDomain = dns.Domain{ASCII: "mail.mox.example"}
+	mox.Conf.Static.DataDir = "."
+	mox.Conf.Dynamic.Domains = map[string]config.Domain{
+		"test.xmox.nl": {},
+	}
+
+	dbpath := mox.DataDirPath("tlsrpt.db")
+	os.MkdirAll(filepath.Dir(dbpath), 0770)
+	defer os.Remove(dbpath)
+	defer os.Remove(mox.DataDirPath("tlsrptresult.db"))
+
+	if err := Init(); err != nil {
+		t.Fatalf("init database: %s", err)
+	}
+	defer Close()
+
+	reportJSON, err := tlsrpt.Parse(strings.NewReader(reportJSON))
+	if err != nil {
+		t.Fatalf("parsing report: %v", err)
+	}
+	report := reportJSON.Convert()
+	if err := AddReport(ctxbg, pkglog, dns.Domain{ASCII: "company-y.example"}, "tlsrpt@company-y.example", false, &report); err != nil {
+		t.Fatalf("adding report to database: %s", err)
+	}
+
+	start, _ := time.Parse(time.RFC3339, "2016-04-01T00:00:00Z")
+	end, _ := time.Parse(time.RFC3339, "2016-04-01T23:59:59Z")
+	a, err := Analyze(ctxbg, start, end, dns.Domain{ASCII: "test.xmox.nl"})
+	if err != nil {
+		t.Fatalf("analyze: %v", err)
+	}
+	if a.Reports != 1 || a.Success != 5326 || a.Failure != 303 || len(a.Days) != 1 || len(a.Reporters) != 1 || a.Reporters[0].OrganizationName != "Company-X" {
+		t.Fatalf("unexpected analytics %#v", a)
+	}
+	expCategories := []StatsCategory{{CategorySTARTTLS, 200}, {CategoryCertificate, 100}, {CategoryOther, 3}}
+	if !reflect.DeepEqual(a.Categories, expCategories) {
+		t.Fatalf("categories, got %#v, expected %#v", a.Categories, expCategories)
+	}
+	if len(a.ResultTypes) != 3 || a.ResultTypes[0].ResultType != tlsrpt.ResultSTARTTLSNotSupported || !reflect.DeepEqual(a.ResultTypes[0].MXHosts, []string{"mx2.mail.company-y.example"}) {
+		t.Fatalf("unexpected result types %#v", a.ResultTypes)
+	}
+
+	// First report: all failure types are new. Without postmaster account
+	// configured, no alert is delivered.
+	now := time.Now()
+	if err := CheckNewFailureTypes(ctxbg, pkglog, &report, now); err != nil {
+		t.Fatalf("check new failure types: %v", err)
+	}
+	fts, err := FailureTypes(ctxbg, dns.Domain{ASCII: "test.xmox.nl"})
+	if err != nil || len(fts) != 3 {
+		t.Fatalf("got err %v, failure types %#v, expected 3", err, fts)
+	}
+
+	// Same types again are updated, not added.
+	if err := CheckNewFailureTypes(ctxbg, pkglog, &report, now.Add(time.Hour)); err != nil {
+		t.Fatalf("check new failure types: %v", err)
+	}
+	fts, err = FailureTypes(ctxbg, dns.Domain{})
+	if err != nil || len(fts) != 3 {
+		t.Fatalf("got err %v, failure types %#v, expected 3", err, fts)
+	}
+	var sessions int64
+	for _, ft := range fts {
+		if !ft.Last.Equal(now.Add(time.Hour)) || !ft.First.Equal(now) {
+			t.Fatalf("unexpected first/last for failure type %#v", ft)
+		}
+		sessions += ft.Sessions
+	}
+	if sessions != 2*303 {
+		t.Fatalf("got %d failed sessions, expected %d", sessions, 2*303)
+	}
+}
@@ -0,0 +1,362 @@
+package tlsrptdb
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/tlsrpt"
+)
+
+// FailureCategory groups TLS result types into the kind of problem they
+// indicate.
+type FailureCategory string
+
+const (
+	CategorySTARTTLS    FailureCategory = "starttls"    // STARTTLS not offered/denied.
+	CategoryCertificate FailureCategory = "certificate" // Certificate expired, untrusted or host mismatch.
+	CategoryDANE        FailureCategory = "dane"        // TLSA records or DNSSEC invalid, DANE required.
+	CategoryMTASTS      FailureCategory = "mtasts"      // MTA-STS policy fetch or validation failed.
+	CategoryOther       FailureCategory = "other"
+)
+
+// ResultTypeCategory returns the category for a TLS result type.
+func ResultTypeCategory(rt tlsrpt.ResultType) FailureCategory {
+	switch rt {
+	case tlsrpt.ResultSTARTTLSNotSupported:
+		return CategorySTARTTLS
+	case tlsrpt.ResultCertificateHostMismatch, tlsrpt.ResultCertificateExpired, tlsrpt.ResultCertificateNotTrusted:
+		return CategoryCertificate
+	case tlsrpt.ResultTLSAInvalid, tlsrpt.ResultDNSSECInvalid, tlsrpt.ResultDANERequired:
+		return CategoryDANE
+	case tlsrpt.ResultSTSPolicyInvalid, tlsrpt.ResultSTSWebPKIInvalid, tlsrpt.ResultSTSPolicyFetch:
+		return CategoryMTASTS
+	}
+	return CategoryOther
+}
+
+// Stats summarizes the TLS reports for a policy domain over a period.
+type Stats struct {
+	PolicyDomain string // Empty for all domains.
+	Reports      int
+	Success      int64
+	Failure      int64
+
+	Categories  []StatsCategory   // Most failed sessions first.
+	ResultTypes []StatsResultType // Most failed sessions first.
+	Days        []StatsDay        // Sorted by day, for trends.
+	Reporters   []StatsReporter   // Most sessions first.
+}
+
+// StatsCategory holds the number of failed sessions for a failure category.
+type StatsCategory struct {
+	Category FailureCategory
+	Failure  int64
+}
+
+// StatsResultType holds failure details for a result type.
+type StatsResultType struct {
+	ResultType tlsrpt.ResultType
+	Category   FailureCategory
+	Failure    int64    // Failed sessions.
+	Reports    int      // Reports mentioning this result type.
+	MXHosts    []string // Receiving MX hostnames, as reported.
+	Reasons    []string // Failure reason codes, as reported.
+}
+
+// StatsDay holds session counts for reports with a period starting on a day.
+type StatsDay struct {
+	Day     string // In UTC, "2006-01-02".
+	Success int64
+	Failure int64
+}
+
+// StatsReporter holds session counts for an organization sending reports.
+type StatsReporter struct {
+	OrganizationName string
+	Reports          int
+	Success          int64
+	Failure          int64
+}
+
+// Analyze gathers statistics for the reports overlapping period start/end for
+// the policy domain, or all domains if zero.
+func Analyze(ctx context.Context, start, end time.Time, policyDomain dns.Domain) (Stats, error) {
+	records, err := RecordsPeriodDomain(ctx, start, end, policyDomain)
+	if err != nil {
+		return Stats{}, err
+	}
+	return analyze(records, policyDomain), nil
+}
+
+func analyze(records []Record, policyDomain dns.Domain) Stats {
+	a := Stats{PolicyDomain: policyDomain.Name(), Reports: len(records)}
+
+	categories := map[FailureCategory]*StatsCategory{}
+	resultTypes := map[tlsrpt.ResultType]*StatsResultType{}
+	days := map[string]*StatsDay{}
+	reporters := map[string]*StatsReporter{}
+
+	addUniq := func(l []string, s string) []string {
+		if s == "" || slices.Contains(l, s) {
+			return l
+		}
+		return append(l, s)
+	}
+
+	for _, r := range records {
+		day := r.Report.DateRange.Start.UTC().Format("2006-01-02")
+		d := days[day]
+		if d == nil {
+			d = &StatsDay{Day: day}
+			days[day] = d
+		}
+		rep := reporters[r.Report.OrganizationName]
+		if rep == nil {
+			rep = &StatsReporter{OrganizationName: r.Report.OrganizationName}
+			reporters[r.Report.OrganizationName] = rep
+		}
+		rep.Reports++
+
+		seen := map[tlsrpt.ResultType]bool{}
+		for _, p := range r.Report.Policies {
+			// Reports can contain results for other domains, only count ours.
+			if !strings.EqualFold(p.Policy.Domain, r.Domain) {
+				if pd, err := dns.ParseDomain(p.Policy.Domain); err != nil || pd.Name() != r.Domain {
+					continue
+				}
+			}
+
+			a.Success += p.Summary.TotalSuccessfulSessionCount
+			a.Failure += p.Summary.TotalFailureSessionCount
+			d.Success += p.Summary.TotalSuccessfulSessionCount
+			d.Failure += p.Summary.TotalFailureSessionCount
+			rep.Success += p.Summary.TotalSuccessfulSessionCount
+			rep.Failure += p.Summary.TotalFailureSessionCount
+
+			for _, fd := range p.FailureDetails {
+				cat := ResultTypeCategory(fd.ResultType)
+				c := categories[cat]
+				if c == nil {
+					c = &StatsCategory{Category: cat}
+					categories[cat] = c
+				}
+				c.Failure += fd.FailedSessionCount
+
+				rt := resultTypes[fd.ResultType]
+				if rt == nil {
+					rt = &StatsResultType{ResultType: fd.ResultType, Category: cat}
+					resultTypes[fd.ResultType] = rt
+				}
+				rt.Failure += fd.FailedSessionCount
+				if !seen[fd.ResultType] {
+					seen[fd.ResultType] = true
+					rt.Reports++
+				}
+				rt.MXHosts = addUniq(rt.MXHosts, strings.ToLower(fd.ReceivingMXHostname))
+				rt.Reasons = addUniq(rt.Reasons, fd.FailureReasonCode)
+			}
+		}
+	}
+
+	for _, c := range categories {
+		a.Categories = append(a.Categories, *c)
+	}
+	slices.SortFunc(a.Categories, func(x, y StatsCategory) int {
+		if x.Failure != y.Failure {
+			return int(y.Failure - x.Failure)
+		}
+		return strings.Compare(string(x.Category), string(y.Category))
+	})
+
+	for _, rt := range resultTypes {
+		slices.Sort(rt.MXHosts)
+		slices.Sort(rt.Reasons)
+		a.ResultTypes = append(a.ResultTypes, *rt)
+	}
+	slices.SortFunc(a.ResultTypes, func(x, y StatsResultType) int {
+		if x.Failure != y.Failure {
+			return int(y.Failure - x.Failure)
+		}
+		return strings.Compare(string(x.ResultType), string(y.ResultType))
+	})
+
+	for _, d := range days {
+		a.Days = append(a.Days, *d)
+	}
+	slices.SortFunc(a.Days, func(x, y StatsDay) int { return strings.Compare(x.Day, y.Day) })
+
+	for _, rep := range reporters {
+		a.Reporters = append(a.Reporters, *rep)
+	}
+	slices.SortFunc(a.Reporters, func(x, y StatsReporter) int {
+		xt, yt := x.Success+x.Failure, y.Success+y.Failure
+		if xt != yt {
+			return int(yt - xt)
+		}
+		return strings.Compare(x.OrganizationName, y.OrganizationName)
+	})
+
+	return a
+}
+
+// FailureType records when a result type was first and last seen in a TLS
+// report for a policy domain. Used to alert about new types of failures.
+type FailureType struct {
+	ID           int64
+	PolicyDomain string            `bstore:"unique PolicyDomain+ResultType,nonzero"` // Unicode.
+	ResultType   tlsrpt.ResultType `bstore:"nonzero"`
+	First        time.Time         `bstore:"default now"`
+	Last         time.Time         `bstore:"default now"`
+	Sessions     int64             // Total failed sessions seen.
+}
+
+// FailureTypes returns the failure types seen for a policy domain, or all
+// domains if zero, most recently seen first.
+func FailureTypes(ctx context.Context, policyDomain dns.Domain) ([]FailureType, error) {
+	q := bstore.QueryDB[FailureType](ctx, ReportDB)
+	var zerodom dns.Domain
+	if policyDomain != zerodom {
+		q.FilterNonzero(FailureType{PolicyDomain: policyDomain.Name()})
+	}
+	q.SortDesc("Last")
+	return q.List()
+}
+
+// CheckNewFailureTypes records the failure result types in a TLS report for our
+// policy domains, and delivers an alert message to the operator for failure
+// types not seen before for a policy domain.
+//
+// The alert is delivered to the account and mailbox configured for TLS reports
+// of the policy domain, or for the host TLS reports if the policy domain is our
+// hostname. If neither is configured, the alert goes to the postmaster mailbox.
+func CheckNewFailureTypes(ctx context.Context, log mlog.Log, r *tlsrpt.Report, now time.Time) error {
+	newTypes := map[dns.Domain][]tlsrpt.FailureDetails{}
+	var domains []dns.Domain
+
+	err := ReportDB.Write(ctx, func(tx *bstore.Tx) error {
+		for _, p := range r.Policies {
+			d, err := dns.ParseDomain(p.Policy.Domain)
+			if err != nil {
+				continue
+			}
+			if _, ok := mox.Conf.Domain(d); !ok && d != mox.Conf.Static.HostnameDomain {
+				continue
+			}
+			for _, fd := range p.FailureDetails {
+				ft, err := bstore.QueryTx[FailureType](tx).FilterNonzero(FailureType{PolicyDomain: d.Name(), ResultType: fd.ResultType}).Get()
+				if err == bstore.ErrAbsent {
+					ft = FailureType{PolicyDomain: d.Name(), ResultType: fd.ResultType, First: now, Last: now, Sessions: fd.FailedSessionCount}
+					if err := tx.Insert(&ft); err != nil {
+						return fmt.Errorf("inserting failure type: %v", err)
+					}
+					if _, ok := newTypes[d]; !ok {
+						domains = append(domains, d)
+					}
+					newTypes[d] = append(newTypes[d], fd)
+					continue
+				} else if err != nil {
+					return fmt.Errorf("looking up failure type: %v", err)
+				}
+				ft.Last = now
+				ft.Sessions += fd.FailedSessionCount
+				if err := tx.Update(&ft); err != nil {
+					return fmt.Errorf("updating failure type: %v", err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, d := range domains {
+		accountName, mailbox := alertDestination(d)
+		if accountName == "" {
+			log.Info("new tls failure type for domain, but no account configured for alerts", slog.Any("domain", d))
+			continue
+		}
+		log.Info("new tls failure type for domain, sending alert", slog.Any("domain", d), slog.Int("types", len(newTypes[d])))
+		if err := deliverFailureTypeAlert(log, d, accountName, mailbox, r, newTypes[d], now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// alertDestination returns the account and mailbox for alerts about policy
+// domain d.
+func alertDestination(d dns.Domain) (accountName, mailbox string) {
+	if dc, ok := mox.Conf.Domain(d); ok && dc.TLSRPT != nil {
+		return dc.TLSRPT.Account, dc.TLSRPT.Mailbox
+	}
+	if d == mox.Conf.Static.HostnameDomain && mox.Conf.Static.HostTLSRPT.Account != "" {
+		return mox.Conf.Static.HostTLSRPT.Account, mox.Conf.Static.HostTLSRPT.Mailbox
+	}
+	return mox.Conf.Static.Postmaster.Account, mox.Conf.Static.Postmaster.Mailbox
+}
+
+// deliverFailureTypeAlert delivers a message about new failure types for a
+// policy domain.
+func deliverFailureTypeAlert(log mlog.Log, d dns.Domain, accountName, mailbox string, r *tlsrpt.Report, details []tlsrpt.FailureDetails, now time.Time) error {
+	acc, err := store.OpenAccount(log, accountName, false)
+	if err != nil {
+		return fmt.Errorf("open account for tls failure alert: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	f, err := store.CreateMessageTemp(log, "tlsrpt-alert")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "tls failure alert")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi!\r\n\r\nA TLS report from %s for %s contains types of failures not seen before.\r\n\r\n", r.OrganizationName, d)
+	for _, fd := range details {
+		fmt.Fprintf(&b, "- %s (%s): %d failed sessions", fd.ResultType, ResultTypeCategory(fd.ResultType), fd.FailedSessionCount)
+		if fd.ReceivingMXHostname != "" {
+			fmt.Fprintf(&b, ", MX host %s", fd.ReceivingMXHostname)
+		}
+		if fd.SendingMTAIP != "" {
+			fmt.Fprintf(&b, ", from IP %s", fd.SendingMTAIP)
+		}
+		if fd.FailureReasonCode != "" {
+			fmt.Fprintf(&b, ", reason %s", fd.FailureReasonCode)
+		}
+		fmt.Fprintf(&b, "\r\n")
+	}
+	fmt.Fprintf(&b, "\r\nRemote servers may not be able to deliver email over TLS, or may fail to deliver at all if MTA-STS or DANE is enforced. See the TLS reports in the admin web interface for details.\r\n\r\nCheers,\r\nmox\r\n")
+
+	n, err := fmt.Fprintf(f, "Date: %s\r\nSubject: New TLS failures for %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8-bit\r\n\r\n%s", now.Format(message.RFC5322Z), d, b.String())
+	if err != nil {
+		return fmt.Errorf("writing tls failure alert: %v", err)
+	}
+
+	m := store.Message{
+		Received: now,
+		Flags:    store.Flags{Flagged: true},
+		Size:     int64(n),
+	}
+	acc.WithWLock(func() {
+		err = acc.DeliverMailbox(log, mailbox, &m, f)
+	})
+	if err != nil {
+		return fmt.Errorf("delivering tls failure alert: %v", err)
+	}
+	return nil
+}
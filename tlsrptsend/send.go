@@ -22,6 +22,7 @@ import (
 	"log/slog"
 	"mime"
 	"mime/multipart"
+	"net/http"
 	"net/textproto"
 	"net/url"
 	"os"
@@ -59,6 +60,12 @@ var (
 			Help: "Total messages with TLS reports queued.",
 		},
 	)
+	metricReportHTTPS = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Name: "mox_tlsrptsend_report_https_total",
+			Help: "Total TLS reports sent with HTTPS POST.",
+		},
+	)
 	metricReportError = promauto.NewCounter(
 		prometheus.CounterOpts{
 			Name: "mox_tlsrptsend_report_error_total",
@@ -281,6 +288,33 @@ func sendReports(ctx context.Context, log mlog.Log, resolver dns.Resolver, db *b
 // replaceable for testing.
 var queueAdd = queue.Add
 
+// httpClient posts reports to URIs from TLSRPT records of remote domains, so it
+// only connects to public IPs.
+var httpClient = mox.PublicHTTPClient(time.Minute)
+
+// postReport sends a gzipped JSON TLS report to an https URI from the rua field of
+// a TLSRPT record. Replaced by tests.
+var postReport = func(ctx context.Context, log mlog.Log, uri string, report io.Reader) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, "POST", uri, report)
+	if err != nil {
+		return fmt.Errorf("making request: %v", err)
+	}
+	// ../rfc/8460:1055
+	req.Header.Set("Content-Type", "application/tlsrpt+gzip")
+	req.Header.Set("User-Agent", "mox/"+moxvar.Version)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("remote responded with status %s", resp.Status)
+	}
+	return nil
+}
+
 func sendReportDomain(ctx context.Context, log mlog.Log, resolver dns.Resolver, db *bstore.DB, endUTC time.Time, isRcptDom bool, policyDomain, dayUTC string) (cleanup bool, rerr error) {
 	polDom, err := dns.ParseDomain(policyDomain)
 	if err != nil {
@@ -340,6 +374,7 @@ func sendReportDomain(ctx context.Context, log mlog.Log, resolver dns.Resolver,
 
 	var recipients []message.NameAddress
 	var recipientStrs []string
+	var httpsURIs []string
 
 	for _, l := range record.RUAs {
 		for _, s := range l {
@@ -367,16 +402,22 @@ func sendReportDomain(ctx context.Context, log mlog.Log, resolver dns.Resolver,
 				// requirement is specified for HTTPS, but no one is going to accept
 				// unauthenticated TLS reports over HTTPS. So there seems little point in sending
 				// them.
+				// We only send them when explicitly configured.
 				// ../rfc/8460:320 ../rfc/8460:1055
 				// todo spec: would be good to have clearer distinction between "report" (JSON) and "report message" (message with report attachment, that can be DKIM signed). propose sending report message over https that includes DKIM signature so authenticity can be verified and the report used. ../rfc/8460:310
-				log.Debug("https scheme in rua uri in tlsrpt record, ignoring since they will likey not be used to due lack of authentication", slog.Any("rua", s))
+				if !mox.Conf.Static.OutgoingTLSReportsHTTPS {
+					log.Debug("https scheme in rua uri in tlsrpt record, ignoring since they will likey not be used to due lack of authentication", slog.Any("rua", s))
+					continue
+				}
+				httpsURIs = append(httpsURIs, string(s))
+				recipientStrs = append(recipientStrs, string(s))
 			} else {
 				log.Debug("unknown scheme in rua uri in tlsrpt record, ignoring", slog.Any("rua", s))
 			}
 		}
 	}
 
-	if len(recipients) == 0 {
+	if len(recipients) == 0 && len(httpsURIs) == 0 {
 		// No reports requested, perfectly fine, no work to do for us.
 		log.Debug("no tlsrpt reporting addresses configured")
 		return true, nil
@@ -500,6 +541,56 @@ func sendReportDomain(ctx context.Context, log mlog.Log, resolver dns.Resolver,
 		return false, fmt.Errorf("writing tls report as json with gzip: %v", err)
 	}
 
+	// Already mark the report as sent. If it won't succeed below, it probably won't
+	// succeed on a later retry either. And if we would fail to mark a report as sent
+	// after sending it, we may sent duplicates or even get in some kind of sending
+	// loop.
+	err = db.Write(ctx, func(tx *bstore.Tx) error {
+		if isRcptDom {
+			q := bstore.QueryTx[tlsrptdb.TLSResult](tx)
+			q.FilterNonzero(tlsrptdb.TLSResult{DayUTC: dayUTC, RecipientDomain: policyDomain})
+			_, err := q.UpdateNonzero(tlsrptdb.TLSResult{SentToRecipientDomain: true})
+			if err != nil {
+				return fmt.Errorf("already marking tls results as sent for recipient domain: %v", err)
+			}
+
+			// Also set reporting addresses for the recipient domain results.
+			q = bstore.QueryTx[tlsrptdb.TLSResult](tx)
+			q.FilterNonzero(tlsrptdb.TLSResult{DayUTC: dayUTC, RecipientDomain: policyDomain})
+			_, err = q.UpdateNonzero(tlsrptdb.TLSResult{RecipientDomainReportingAddresses: recipientStrs})
+			if err != nil {
+				return fmt.Errorf("storing recipient domain reporting addresses: %v", err)
+			}
+		} else {
+			q := bstore.QueryTx[tlsrptdb.TLSResult](tx)
+			q.FilterNonzero(tlsrptdb.TLSResult{DayUTC: dayUTC, PolicyDomain: policyDomain})
+			_, err := q.UpdateNonzero(tlsrptdb.TLSResult{SentToPolicyDomain: true})
+			if err != nil {
+				return fmt.Errorf("already marking tls results as sent for policy domain: %v", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return false, fmt.Errorf("marking tls results as sent: %v", err)
+	}
+
+	// Send report to https URIs. We don't retry, just like we don't retry queueing
+	// messages.
+	for _, uri := range httpsURIs {
+		if err := postReport(ctx, log, uri, &moxio.AtReader{R: reportFile}); err != nil {
+			log.Errorx("sending tls report with https", err, slog.String("uri", uri))
+			metricReportError.Inc()
+		} else {
+			log.Debug("tls report sent with https", slog.String("uri", uri))
+			metricReportHTTPS.Inc()
+		}
+	}
+
+	if len(recipients) == 0 {
+		return true, nil
+	}
+
 	msgf, err := store.CreateMessageTemp(log, "tlsreportmsgout")
 	if err != nil {
 		return false, fmt.Errorf("creating temporary message file with outgoing tls report: %v", err)
@@ -542,40 +633,6 @@ Period: %s - %s UTC
 	}
 	msgSize := int64(len(msgPrefix)) + msgInfo.Size()
 
-	// Already mark the report as sent. If it won't succeed below, it probably won't
-	// succeed on a later retry either. And if we would fail to mark a report as sent
-	// after sending it, we may sent duplicates or even get in some kind of sending
-	// loop.
-	err = db.Write(ctx, func(tx *bstore.Tx) error {
-		if isRcptDom {
-			q := bstore.QueryTx[tlsrptdb.TLSResult](tx)
-			q.FilterNonzero(tlsrptdb.TLSResult{DayUTC: dayUTC, RecipientDomain: policyDomain})
-			_, err := q.UpdateNonzero(tlsrptdb.TLSResult{SentToRecipientDomain: true})
-			if err != nil {
-				return fmt.Errorf("already marking tls results as sent for recipient domain: %v", err)
-			}
-
-			// Also set reporting addresses for the recipient domain results.
-			q = bstore.QueryTx[tlsrptdb.TLSResult](tx)
-			q.FilterNonzero(tlsrptdb.TLSResult{DayUTC: dayUTC, RecipientDomain: policyDomain})
-			_, err = q.UpdateNonzero(tlsrptdb.TLSResult{RecipientDomainReportingAddresses: recipientStrs})
-			if err != nil {
-				return fmt.Errorf("storing recipient domain reporting addresses: %v", err)
-			}
-		} else {
-			q := bstore.QueryTx[tlsrptdb.TLSResult](tx)
-			q.FilterNonzero(tlsrptdb.TLSResult{DayUTC: dayUTC, PolicyDomain: policyDomain})
-			_, err := q.UpdateNonzero(tlsrptdb.TLSResult{SentToPolicyDomain: true})
-			if err != nil {
-				return fmt.Errorf("already marking tls results as sent for policy domain: %v", err)
-			}
-		}
-		return nil
-	})
-	if err != nil {
-		return false, fmt.Errorf("marking tls results as sent: %v", err)
-	}
-
 	var queued bool
 	for _, rcpt := range recipients {
 		// If recipient is on suppression list, we won't queue the reporting message.
@@ -1,12 +1,17 @@
 package tlsrptsend
 
 import (
+	"compress/gzip"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -25,6 +30,9 @@ import (
 
 var ctxbg = context.Background()
 
+// Before tests replace it.
+var origPostReport = postReport
+
 func tcheckf(t *testing.T, err error, format string, args ...any) {
 	t.Helper()
 	if err != nil {
@@ -54,7 +62,7 @@ func TestSendReports(t *testing.T) {
 	resolver := dns.MockResolver{
 		TXT: map[string][]string{
 			"_smtp._tls.xn--74h.example.": {
-				"v=TLSRPTv1; rua=mailto:tlsreports@xn--74h.example,https://ignored.example/",
+				"v=TLSRPTv1; rua=mailto:tlsreports@xn--74h.example,https://tlsrpt.xn--74h.example/",
 			},
 			"_smtp._tls.mailhost.xn--74h.example.": {
 				"v=TLSRPTv1; rua=mailto:tlsreports1@mailhost.xn--74h.example,mailto:tlsreports2@mailhost.xn--74h.example; rua=mailto:tlsreports3@mailhost.xn--74h.example",
@@ -435,6 +443,18 @@ func TestSendReports(t *testing.T) {
 
 			return nil
 		}
+		postReport = func(ctx context.Context, log mlog.Log, uri string, report io.Reader) error {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			gzr, err := gzip.NewReader(report)
+			tcheckf(t, err, "gzip reader for report")
+			reportJSON, err := tlsrpt.Parse(gzr)
+			tcheckf(t, err, "parsing posted report")
+
+			haveReports[uri] = append(haveReports[uri], reportJSON.Convert())
+			return nil
+		}
 
 		Start(resolver)
 		// Run first loop.
@@ -517,4 +537,25 @@ func TestSendReports(t *testing.T) {
 		"tlsreports@xn--74h.example":           {report1},
 		"tlsreports2@mailhost.xn--74h.example": {report2},
 	})
+
+	// With https enabled, reports are also posted to https URIs.
+	mox.Conf.Static.OutgoingTLSReportsHTTPS = true
+	test(tlsResults, map[string][]tlsrpt.Report{
+		"tlsreports@xn--74h.example":           {report1},
+		"https://tlsrpt.xn--74h.example/":      {report1},
+		"tlsreports2@mailhost.xn--74h.example": {report2},
+	})
+}
+
+// Reports are only posted to public IPs, rua URIs come from remote DNS records.
+func TestPostReportAddress(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to internal address")
+	}))
+	defer srv.Close()
+
+	err := origPostReport(ctxbg, mlog.New("tlsrptsend", nil), srv.URL, strings.NewReader("report"))
+	if !errors.Is(err, mox.ErrAddressNotAllowed) {
+		t.Fatalf("got err %v, expected ErrAddressNotAllowed", err)
+	}
 }
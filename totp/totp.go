@@ -0,0 +1,114 @@
+// Package totp implements time-based one-time passwords (TOTP), RFC 6238, as
+// used by authenticator apps for two-factor authentication.
+//
+// Only the parameters commonly supported by authenticator apps are implemented:
+// HMAC-SHA1, 6 digits and a period of 30 seconds.
+package totp
+
+import (
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Period is the duration of a time step, during which a code is valid.
+const Period = 30 * time.Second
+
+// Digits is the number of digits in a code.
+const Digits = 6
+
+// Codes from this many time steps before and after the current time step are
+// also accepted, to accommodate clock skew and slow typists.
+const skew = 1
+
+var b32 = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// NewSecret returns a new random secret, of 20 bytes as recommended for SHA1.
+func NewSecret() []byte {
+	buf := make([]byte, 20)
+	cryptorand.Read(buf)
+	return buf
+}
+
+// EncodeSecret returns the secret in base32 without padding, as typically
+// entered in authenticator apps manually.
+func EncodeSecret(secret []byte) string {
+	return b32.EncodeToString(secret)
+}
+
+// DecodeSecret parses a secret as encoded by EncodeSecret.
+func DecodeSecret(s string) ([]byte, error) {
+	return b32.DecodeString(strings.ToUpper(strings.TrimRight(strings.TrimSpace(s), "=")))
+}
+
+// URL returns an otpauth URL for configuring an authenticator app, typically
+// through a QR code. Issuer is the service, e.g. the mail server hostname, and
+// account the login name.
+func URL(issuer, account string, secret []byte) string {
+	u := url.URL{
+		Scheme: "otpauth",
+		Host:   "totp",
+		Path:   "/" + issuer + ":" + account,
+	}
+	q := url.Values{}
+	q.Set("secret", EncodeSecret(secret))
+	q.Set("issuer", issuer)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Step returns the time step for t.
+func Step(t time.Time) int64 {
+	return t.Unix() / int64(Period/time.Second)
+}
+
+// Code returns the code for secret at time step.
+func Code(secret []byte, step int64) string {
+	return hotp(secret, step, Digits)
+}
+
+// hotp returns an HMAC-based one-time password, RFC 4226.
+func hotp(secret []byte, counter int64, digits int) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], uint64(counter))
+	mac := hmac.New(sha1.New, secret)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation.
+	offset := sum[len(sum)-1] & 0xf
+	v := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	mod := uint32(1)
+	for range digits {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, v%mod)
+}
+
+// Verify checks code against the codes for secret around time now. Codes for
+// time steps at or before lastStep are rejected, preventing replay of a code
+// that was already used. On success, the time step of the code is returned, to
+// be stored as the new lastStep.
+func Verify(secret []byte, code string, now time.Time, lastStep int64) (step int64, ok bool) {
+	code = strings.ReplaceAll(code, " ", "")
+	if len(code) != Digits {
+		return 0, false
+	}
+	cur := Step(now)
+	for s := cur - skew; s <= cur+skew; s++ {
+		if s <= lastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(Code(secret, s)), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
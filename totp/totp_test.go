@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHOTP(t *testing.T) {
+	// Test vectors from RFC 4226, appendix D.
+	secret := []byte("12345678901234567890")
+	exp := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	for i, e := range exp {
+		if code := hotp(secret, int64(i), 6); code != e {
+			t.Fatalf("hotp counter %d: got %s, expected %s", i, code, e)
+		}
+	}
+}
+
+func TestTOTP(t *testing.T) {
+	// SHA1 test vectors from RFC 6238, appendix B.
+	secret := []byte("12345678901234567890")
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "94287082"},
+		{1111111109, "07081804"},
+		{1111111111, "14050471"},
+		{1234567890, "89005924"},
+		{2000000000, "69279037"},
+		{20000000000, "65353130"},
+	}
+	for _, tc := range tests {
+		if code := hotp(secret, Step(time.Unix(tc.unix, 0)), 8); code != tc.code {
+			t.Fatalf("totp at %d: got %s, expected %s", tc.unix, code, tc.code)
+		}
+	}
+
+	now := time.Unix(1234567890, 0)
+	code := Code(secret, Step(now))
+	step, ok := Verify(secret, code, now, 0)
+	if !ok || step != Step(now) {
+		t.Fatalf("verify current code: got %v %d", ok, step)
+	}
+	// Replay is rejected.
+	if _, ok := Verify(secret, code, now, step); ok {
+		t.Fatalf("replayed code accepted")
+	}
+	// Previous step is accepted, for clock skew, but not older.
+	if _, ok := Verify(secret, Code(secret, Step(now)-1), now, 0); !ok {
+		t.Fatalf("code of previous step rejected")
+	}
+	if _, ok := Verify(secret, Code(secret, Step(now)-2), now, 0); ok {
+		t.Fatalf("old code accepted")
+	}
+	if _, ok := Verify(secret, code[:3]+" "+code[3:], now, 0); !ok {
+		t.Fatalf("code with space rejected")
+	}
+
+	u := URL("mox.example", "mjl@mox.example", secret)
+	if !strings.HasPrefix(u, "otpauth://totp/mox.example:mjl@mox.example?") || !strings.Contains(u, "secret="+EncodeSecret(secret)) {
+		t.Fatalf("bad url %s", u)
+	}
+}
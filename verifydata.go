@@ -18,9 +18,12 @@ import (
 
 	"github.com/mjl-/mox/dmarcdb"
 	"github.com/mjl-/mox/junk"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/moxvar"
 	"github.com/mjl-/mox/mtastsdb"
+	"github.com/mjl-/mox/postmasterdb"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/reputationdb"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/tlsrptdb"
 )
@@ -154,12 +157,11 @@ possibly making them potentially no longer readable by the previous version.
 		checkf(err, path, "checking database file")
 	}
 
-	checkFile := func(dbpath, path string, prefixSize int, size int64) {
-		st, err := os.Stat(path)
+	checkFile := func(dbpath, path string, prefixSize int, size int64, compressed bool) {
+		filesize, _, err := store.MsgFileSize(path, compressed)
 		checkf(err, path, "checking if file exists")
-		if !skipSizeCheck && err == nil && int64(prefixSize)+st.Size() != size {
-			filesize := st.Size()
-			checkf(fmt.Errorf("%s: message size is %d, should be %d (length of MsgPrefix %d + file size %d), see \"mox fixmsgsize\"", path, size, int64(prefixSize)+st.Size(), prefixSize, filesize), dbpath, "checking message size")
+		if !skipSizeCheck && err == nil && int64(prefixSize)+filesize != size {
+			checkf(fmt.Errorf("%s: message size is %d, should be %d (length of MsgPrefix %d + file size %d), see \"mox fixmsgsize\"", path, size, int64(prefixSize)+filesize, prefixSize, filesize), dbpath, "checking message size")
 		}
 	}
 
@@ -177,10 +179,19 @@ possibly making them potentially no longer readable by the previous version.
 				mp := store.MessagePath(m.ID)
 				seen[mp] = struct{}{}
 				p := filepath.Join(dataDir, "queue", mp)
-				checkFile(dbpath, p, len(m.MsgPrefix), m.Size)
+				checkFile(dbpath, p, len(m.MsgPrefix), m.Size, false)
 				return nil
 			})
 			checkf(err, dbpath, "reading messages in queue database to check files")
+
+			err = bstore.QueryDB[queue.MsgDead](ctxbg, db).ForEach(func(m queue.MsgDead) error {
+				mp := filepath.Join("dead", store.MessagePath(m.ID))
+				seen[mp] = struct{}{}
+				p := filepath.Join(dataDir, "queue", mp)
+				checkFile(dbpath, p, len(m.MsgPrefix), m.Size, false)
+				return nil
+			})
+			checkf(err, dbpath, "reading messages in dead-letter queue to check files")
 		}
 
 		// Check that there are no files that could be treated as a message.
@@ -299,7 +310,7 @@ possibly making them potentially no longer readable by the previous version.
 				mp := store.MessagePath(m.ID)
 				seen[mp] = struct{}{}
 				p := filepath.Join(accdir, "msg", mp)
-				checkFile(dbpath, p, len(m.MsgPrefix), m.Size)
+				checkFile(dbpath, p, len(m.MsgPrefix), m.Size, m.Compressed)
 
 				if up.Threads != 2 {
 					return nil
@@ -413,6 +424,23 @@ possibly making them potentially no longer readable by the previous version.
 		}
 	}
 
+	// Check the shared junk filters of domains, in the "junkfilter" directory.
+	checkSharedJunkFilters := func() {
+		jfdir := filepath.Join(dataDir, "junkfilter")
+		l, err := os.ReadDir(jfdir)
+		if err != nil && os.IsNotExist(err) {
+			return
+		}
+		checkf(err, jfdir, "reading junkfilter directory")
+		for _, e := range l {
+			if strings.HasSuffix(e.Name(), ".db") {
+				checkDB(true, filepath.Join(jfdir, e.Name()), junk.DBTypes)
+			} else if !strings.HasSuffix(e.Name(), ".bloom") {
+				log.Printf("warning: %s: unrecognized file in junkfilter directory, ignoring", filepath.Join("junkfilter", e.Name()))
+			}
+		}
+	}
+
 	// Check all files, skipping the known files, queue and accounts directories. Warn
 	// about unknown files. Skip a "tmp" directory. And a "moved" directory, we
 	// probably created it ourselves.
@@ -431,9 +459,9 @@ possibly making them potentially no longer readable by the previous version.
 				p = p[len(dataDir)+1:]
 			}
 			switch p {
-			case "auth.db", "dmarcrpt.db", "dmarceval.db", "mtasts.db", "tlsrpt.db", "tlsrptresult.db", "receivedid.key", "lastknownversion":
+			case "auth.db", "dmarcrpt.db", "dmarceval.db", "mtasts.db", "tlsrpt.db", "tlsrptresult.db", "postmaster.db", "reputation.db", "mlist.db", "receivedid.key", "lastknownversion":
 				return nil
-			case "acme", "queue", "accounts", "tmp", "moved":
+			case "acme", "queue", "accounts", "junkfilter", "tmp", "moved", "dedup":
 				return fs.SkipDir
 			case "moxversion":
 				buf, err := os.ReadFile(dpath)
@@ -455,8 +483,12 @@ possibly making them potentially no longer readable by the previous version.
 	checkDB(true, filepath.Join(dataDir, "mtasts.db"), mtastsdb.DBTypes)
 	checkDB(true, filepath.Join(dataDir, "tlsrpt.db"), tlsrptdb.ReportDBTypes)
 	checkDB(false, filepath.Join(dataDir, "tlsrptresult.db"), tlsrptdb.ResultDBTypes) // After v0.0.7.
+	checkDB(false, filepath.Join(dataDir, "postmaster.db"), postmasterdb.DBTypes)
+	checkDB(false, filepath.Join(dataDir, "reputation.db"), reputationdb.DBTypes)
+	checkDB(false, filepath.Join(dataDir, "mlist.db"), mlist.DBTypes)
 	checkQueue()
 	checkAccounts()
+	checkSharedJunkFilters()
 	checkOther()
 
 	if backupmoxversion != moxvar.Version {
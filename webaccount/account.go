@@ -18,6 +18,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"time"
 
@@ -30,10 +31,13 @@ import (
 
 	"github.com/mjl-/mox/admin"
 	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/oidc"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/signup"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/webapi"
@@ -104,7 +108,7 @@ func Handler(cookiePath string, isForwarded bool) func(w http.ResponseWriter, r
 			http.Error(w, "500 - internal server error - cannot handle requests", http.StatusInternalServerError)
 			return
 		}
-		handle(sh, isForwarded, w, r)
+		handle(sh, cookiePath, isForwarded, w, r)
 	}
 }
 
@@ -137,6 +141,15 @@ func xcheckuserf(ctx context.Context, err error, format string, args ...any) {
 	panic(&sherpa.Error{Code: "user:error", Message: errmsg})
 }
 
+// xcheckFeature causes a user error if the feature is disabled for the account,
+// through the web config of its domain.
+func xcheckFeature(ctx context.Context, feature string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	if mox.Conf.WebFeatureDisabled(reqInfo.AccountName, feature) {
+		panic(&sherpa.Error{Code: "user:error", Message: fmt.Sprintf("feature %s is disabled for this account", feature)})
+	}
+}
+
 // Account exports web API functions for the account web interface. All its
 // methods are exported under api/. Function calls require valid HTTP
 // Authentication credentials of a user.
@@ -145,7 +158,7 @@ type Account struct {
 	isForwarded bool   // From listener, whether we look at X-Forwarded-* headers.
 }
 
-func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+func handle(apiHandler http.Handler, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request) {
 	ctx := context.WithValue(r.Context(), mlog.CidKey, mox.Cid())
 	log := pkglog.WithContext(ctx).With(slog.String("userauth", ""))
 
@@ -206,6 +219,11 @@ func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r
 		}
 	}
 
+	// Per-domain customization of the interface, without authentication.
+	if webauth.ServeBranding(log, isForwarded, w, r) {
+		return
+	}
+
 	// HTML/JS can be retrieved without authentication.
 	if r.URL.Path == "/" {
 		switch r.Method {
@@ -223,6 +241,27 @@ func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r
 			http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
 		}
 		return
+	} else if r.URL.Path == "/oidc/login" {
+		// Login through the OpenID Connect identity provider, if configured.
+		webauth.OIDCLogin(ctx, log, webauth.Accounts, "webaccount", cookiePath, isForwarded, w, r)
+		return
+	} else if r.URL.Path == "/oidc/callback" {
+		webauth.OIDCCallback(ctx, log, webauth.Accounts, "webaccount", cookiePath, isForwarded, w, r)
+		return
+	} else if r.URL.Path == "/signup" {
+		http.Redirect(w, r, "signup/", http.StatusFound)
+		return
+	} else if strings.HasPrefix(r.URL.Path, "/signup/") {
+		// Self-service signup for new accounts, if configured.
+		http.StripPrefix("/signup", signup.Handler(isForwarded)).ServeHTTP(w, r)
+		return
+	} else if r.URL.Path == "/list" {
+		http.Redirect(w, r, "list/", http.StatusFound)
+		return
+	} else if strings.HasPrefix(r.URL.Path, "/list/") {
+		// Subscription management for mailing lists.
+		http.StripPrefix("/list", mlist.Handler(isForwarded)).ServeHTTP(w, r)
+		return
 	}
 
 	isAPI := strings.HasPrefix(r.URL.Path, "/api/")
@@ -234,8 +273,8 @@ func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r
 
 	var loginAddress, accName string
 	var sessionToken store.SessionToken
-	// All other URLs, except the login endpoint require some authentication.
-	if r.URL.Path != "/api/LoginPrep" && r.URL.Path != "/api/Login" {
+	// All other URLs, except the login endpoints require some authentication.
+	if !webauth.IsLoginPath(r.URL.Path) {
 		var ok bool
 		isExport := r.URL.Path == "/export"
 		requireCSRF := isAPI || r.URL.Path == "/import" || isExport
@@ -249,13 +288,32 @@ func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r
 	if isAPI {
 		reqInfo := requestInfo{loginAddress, accName, sessionToken, w, r}
 		ctx = context.WithValue(ctx, requestInfoCtxKey, reqInfo)
-		apiHandler.ServeHTTP(w, r.WithContext(ctx))
+		method := strings.TrimPrefix(r.URL.Path, "/api/")
+		if kind, ok := auditMethods[method]; ok {
+			event := store.AuditEvent{Kind: kind, Account: accName, Actor: accName, Protocol: "webaccount", Action: method}
+			webauth.AuditAPICall(ctx, log, isForwarded, apiHandler, w, r.WithContext(ctx), event)
+		} else {
+			apiHandler.ServeHTTP(w, r.WithContext(ctx))
+		}
 		return
 	}
 
+	switch r.URL.Path {
+	case "/export", "/import":
+		feature := strings.TrimPrefix(r.URL.Path, "/")
+		if mox.Conf.WebFeatureDisabled(accName, feature) {
+			http.Error(w, "403 - forbidden - "+feature+" is disabled for this account", http.StatusForbidden)
+			return
+		}
+	}
+
 	switch r.URL.Path {
 	case "/export":
-		webops.Export(log, accName, w, r)
+		var remoteIP string
+		if ip := webauth.RemoteIP(log, isForwarded, r); ip != nil {
+			remoteIP = ip.String()
+		}
+		webops.Export(log, accName, "webaccount", remoteIP, w, r)
 
 	case "/import":
 		if r.Method != "POST" {
@@ -347,12 +405,14 @@ func (w Account) LoginPrep(ctx context.Context) string {
 }
 
 // Login returns a session token for the credentials, or fails with error code
-// "user:badLogin". Call LoginPrep to get a loginToken.
-func (w Account) Login(ctx context.Context, loginToken, username, password string) store.CSRFToken {
+// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+// authentication is enabled, totp must be a code from the authenticator app,
+// otherwise the login fails with error code "user:totpRequired".
+func (w Account) Login(ctx context.Context, loginToken, username, password, totp string) store.CSRFToken {
 	log := pkglog.WithContext(ctx)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 
-	csrfToken, err := webauth.Login(ctx, log, webauth.Accounts, "webaccount", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password)
+	csrfToken, err := webauth.Login(ctx, log, webauth.Accounts, "webaccount", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password, totp)
 	if _, ok := err.(*sherpa.Error); ok {
 		panic(err)
 	}
@@ -360,6 +420,43 @@ func (w Account) Login(ctx context.Context, loginToken, username, password strin
 	return csrfToken
 }
 
+// PasskeyLoginStart returns the parameters for the browser to login with a
+// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+// of the browser.
+func (w Account) PasskeyLoginStart(ctx context.Context, loginToken string) webauth.PasskeyGetOptions {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	opts, err := webauth.PasskeyLoginStart(ctx, log, "webaccount", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken)
+	if _, ok := err.(*sherpa.Error); ok {
+		panic(err)
+	}
+	xcheckf(ctx, err, "passkey login")
+	return opts
+}
+
+// PasskeyLogin returns a session token for a passkey login started with
+// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+// password, the passkey must verify the user, e.g. with a PIN. With username and
+// password, the passkey is the second factor.
+func (w Account) PasskeyLogin(ctx context.Context, loginToken, username, password string, assertion webauth.PasskeyAssertion) store.CSRFToken {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	csrfToken, err := webauth.PasskeyLogin(ctx, log, webauth.Accounts, "webaccount", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password, assertion)
+	if _, ok := err.(*sherpa.Error); ok {
+		panic(err)
+	}
+	xcheckf(ctx, err, "passkey login")
+	return csrfToken
+}
+
+// OIDCEnabled returns whether logging in through an OpenID Connect identity
+// provider is possible, by sending the browser to "oidc/login".
+func (Account) OIDCEnabled(ctx context.Context) bool {
+	return oidc.Enabled()
+}
+
 // Logout invalidates the session token.
 func (w Account) Logout(ctx context.Context) {
 	log := pkglog.WithContext(ctx)
@@ -380,7 +477,8 @@ func (Account) Version(ctx context.Context) (version, goos, goarch string) {
 // Sessions are not interrupted, and will keep working. New login attempts must use
 // the new password.
 //
-// Password must be at least 8 characters.
+// Password must be at least 8 characters, and meet the password policy of the
+// account, if any.
 //
 // Setting a user-supplied password is not allowed if NoCustomPassword is set
 // for the account.
@@ -402,6 +500,7 @@ func (Account) SetPassword(ctx context.Context, password string) {
 	if accConf.NoCustomPassword {
 		xcheckuserf(ctx, errors.New("custom password not allowed"), "setting password")
 	}
+	xcheckuserf(ctx, store.CheckPasswordPolicy(accConf, password), "checking password policy")
 
 	// Retrieve session, resetting password invalidates it.
 	ls, err := store.SessionUse(ctx, log, reqInfo.AccountName, reqInfo.SessionToken, "")
@@ -444,10 +543,242 @@ func (Account) GeneratePassword(ctx context.Context) (password string) {
 	return
 }
 
+// PasswordStatus is the state of the password and recovery codes of the account.
+type PasswordStatus struct {
+	Changed       time.Time // Zero if not known, for passwords set with older versions.
+	Expires       time.Time // Zero if the password doesn't expire by age.
+	Expired       bool      // If set, a new password must be set before logging in with other protocols.
+	RecoveryCodes int       // Number of unused recovery codes.
+}
+
+// PasswordStatus returns the state of the password, e.g. whether it has expired,
+// and the number of unused recovery codes.
+func (Account) PasswordStatus(ctx context.Context) (status PasswordStatus) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	accConf, _ := acc.Conf()
+	err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		pw, err := bstore.QueryTx[store.Password](tx).Get()
+		if err == nil {
+			status.Changed = pw.Changed
+			status.Expired = pw.Expired(accConf, time.Now())
+			if pp := accConf.PasswordPolicy; pp != nil && pp.MaxAge > 0 && !pw.Changed.IsZero() {
+				status.Expires = pw.Changed.Add(pp.MaxAge)
+			}
+		} else if err != bstore.ErrAbsent {
+			return err
+		}
+		status.RecoveryCodes, err = bstore.QueryTx[store.RecoveryCode](tx).Count()
+		return err
+	})
+	xcheckf(ctx, err, "get password status")
+	return
+}
+
+// RecoveryCodesGenerate replaces any existing recovery codes with new codes and
+// returns them. Each code can be used once instead of the password to login to the
+// account web interface, after which a new password must be set.
+func (Account) RecoveryCodesGenerate(ctx context.Context) (codes []string) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	codes, err = acc.RecoveryCodesGenerate(log)
+	xcheckf(ctx, err, "generating recovery codes")
+	return codes
+}
+
+// TwoFactorStatus is the state of two-factor authentication for the account.
+type TwoFactorStatus struct {
+	Enabled      bool                // Whether a TOTP code is required for web logins.
+	Required     bool                // Whether the admin requires two-factor authentication for the account.
+	AppPasswords []store.AppPassword // For protocols other than the web interfaces.
+}
+
+// TwoFactorStatus returns whether two-factor authentication is enabled or
+// required, and the app passwords.
+func (Account) TwoFactorStatus(ctx context.Context) (status TwoFactorStatus) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	accConf, _ := acc.Conf()
+	status.Required = store.TwoFactorRequired(accConf)
+	status.Enabled, err = acc.TwoFactorEnabled()
+	xcheckf(ctx, err, "checking two-factor authentication")
+	status.AppPasswords, err = bstore.QueryDB[store.AppPassword](ctx, acc.DB).SortAsc("Created").List()
+	xcheckf(ctx, err, "listing app passwords")
+	return
+}
+
+// TOTPSetupStart starts enabling two-factor authentication with a time-based
+// one-time password (TOTP), returning the parameters for the authenticator app.
+// Call TOTPSetupConfirm with a code from the app to enable it.
+func (Account) TOTPSetupStart(ctx context.Context) webauth.TOTPSetup {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	secret, err := acc.TOTPSetupStart(log)
+	xcheckuserf(ctx, err, "starting two-factor authentication setup")
+	setup, err := webauth.NewTOTPSetup(reqInfo.LoginAddress, secret)
+	xcheckf(ctx, err, "preparing two-factor authentication setup")
+	return setup
+}
+
+// TOTPSetupConfirm enables two-factor authentication after verifying a code
+// from the authenticator app. Logins to the web interfaces then require a code,
+// and other protocols, like IMAP and SMTP, require app passwords.
+func (Account) TOTPSetupConfirm(ctx context.Context, code string) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	err = acc.TOTPSetupConfirm(log, code)
+	if errors.Is(err, store.ErrUnknownCredentials) {
+		xcheckuserf(ctx, errors.New("invalid code"), "confirming two-factor authentication")
+	}
+	xcheckuserf(ctx, err, "confirming two-factor authentication")
+}
+
+// TOTPRemove disables two-factor authentication, after verifying a current code.
+// Not possible when the admin requires two-factor authentication.
+func (Account) TOTPRemove(ctx context.Context, code string) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	if accConf, _ := acc.Conf(); store.TwoFactorRequired(accConf) {
+		xcheckuserf(ctx, errors.New("two-factor authentication is required for this account"), "disabling two-factor authentication")
+	}
+	err = acc.TOTPVerify(code)
+	if errors.Is(err, store.ErrUnknownCredentials) {
+		xcheckuserf(ctx, errors.New("invalid code"), "verifying code")
+	}
+	xcheckf(ctx, err, "verifying code")
+	err = acc.TOTPRemove(log)
+	xcheckf(ctx, err, "disabling two-factor authentication")
+}
+
+// AppPasswordAdd adds an app password for use by a single application, e.g. an
+// IMAP/SMTP client, for the given protocols: "imap", "submission", "webapi",
+// "caldav", "carddav". If ipRanges is non-empty, the password can only be used
+// from those networks, in CIDR notation or single IPs. The generated password is
+// returned, it cannot be retrieved later.
+func (Account) AppPasswordAdd(ctx context.Context, name string, protocols, ipRanges []string) (password string) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	_, password, err = acc.AppPasswordAdd(log, name, protocols, ipRanges)
+	xcheckuserf(ctx, err, "adding app password")
+	return password
+}
+
+// AppPasswordRemove removes an app password.
+func (Account) AppPasswordRemove(ctx context.Context, id int64) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	err = acc.AppPasswordRemove(log, id)
+	xcheckuserf(ctx, err, "removing app password")
+}
+
+// Passkeys returns the passkeys registered for the account.
+func (Account) Passkeys(ctx context.Context) []store.Passkey {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	l, err := store.PasskeyList(ctx, reqInfo.AccountName)
+	xcheckf(ctx, err, "listing passkeys")
+	return l
+}
+
+// PasskeyRegisterStart starts registration of a new passkey, returning the
+// parameters for the browser. Complete the registration with PasskeyRegister.
+func (w Account) PasskeyRegisterStart(ctx context.Context) webauth.PasskeyCreateOptions {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	opts, err := webauth.PasskeyRegisterStart(ctx, "webaccount", w.isForwarded, reqInfo.Request, reqInfo.AccountName, reqInfo.LoginAddress)
+	xcheckf(ctx, err, "starting passkey registration")
+	return opts
+}
+
+// PasskeyRegister completes registration of a passkey with the response from the
+// browser. Name describes the passkey, e.g. the device. Logging in with the
+// passkey starts a session for the current login address.
+func (w Account) PasskeyRegister(ctx context.Context, name string, clientDataJSON, attestationObject []byte) store.Passkey {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	pk, err := webauth.PasskeyRegister(ctx, log, "webaccount", w.isForwarded, reqInfo.Request, reqInfo.AccountName, reqInfo.LoginAddress, name, clientDataJSON, attestationObject)
+	xcheckuserf(ctx, err, "registering passkey")
+	return pk
+}
+
+// PasskeyRemove removes a passkey.
+func (Account) PasskeyRemove(ctx context.Context, credentialID string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	err := store.PasskeyRemove(ctx, reqInfo.AccountName, credentialID)
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, errors.New("passkey not found"), "removing passkey")
+	}
+	xcheckf(ctx, err, "removing passkey")
+}
+
 // Account returns information about the account.
 // StorageUsed is the sum of the sizes of all messages, in bytes.
 // StorageLimit is the maximum storage that can be used, or 0 if there is no limit.
-func (Account) Account(ctx context.Context) (account config.Account, storageUsed, storageLimit int64, suppressions []webapi.Suppression) {
+// DisabledFeatures are features of the interface disabled through the web config
+// of the domain of the account, see config.WebFeatures.
+func (Account) Account(ctx context.Context) (account config.Account, storageUsed, storageLimit int64, suppressions []webapi.Suppression, disabledFeatures []string) {
 	log := pkglog.WithContext(ctx)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 
@@ -475,7 +806,9 @@ func (Account) Account(ctx context.Context) (account config.Account, storageUsed
 	suppressions, err = queue.SuppressionList(ctx, reqInfo.AccountName)
 	xcheckf(ctx, err, "list suppressions")
 
-	return accConf, storageUsed, storageLimit, suppressions
+	web, _ := mox.Conf.AccountWeb(reqInfo.AccountName)
+
+	return accConf, storageUsed, storageLimit, suppressions, web.DisabledFeatures
 }
 
 // AccountSaveFullName saves the full name (used as display name in email messages)
@@ -519,9 +852,98 @@ func (Account) DestinationSave(ctx context.Context, destName string, oldDest, ne
 	xcheckf(ctx, err, "saving destination")
 }
 
+// xaliasMember returns the alias for aliasAddress, checking that memberAddress is
+// an address of the account and a member of the alias.
+func xaliasMember(ctx context.Context, aliasAddress, memberAddress string) smtp.Address {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	accConf, _ := mox.Conf.Account(reqInfo.AccountName)
+	for _, aa := range accConf.Aliases {
+		lp, err := smtp.ParseLocalpart(aa.Alias.LocalpartStr)
+		xcheckf(ctx, err, "parsing alias localpart")
+		addr := smtp.NewAddress(lp, aa.Alias.Domain)
+		if addr.Pack(true) == aliasAddress && aa.SubscriptionAddress == memberAddress {
+			return addr
+		}
+	}
+	xcheckuserf(ctx, errors.New("not found"), "looking up alias membership")
+	panic("not reached")
+}
+
+// xaliasOwned returns the alias for aliasAddress, checking that an address of the
+// account is an owner.
+func xaliasOwned(ctx context.Context, aliasAddress string) smtp.Address {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	accConf, _ := mox.Conf.Account(reqInfo.AccountName)
+	if !slices.Contains(accConf.OwnedAliases, aliasAddress) {
+		xcheckuserf(ctx, errors.New("not found"), "looking up owned alias")
+	}
+	_, alias, ok := mox.Conf.AccountDestination(aliasAddress)
+	if !ok || alias == nil {
+		xcheckuserf(ctx, errors.New("not found"), "looking up alias")
+	}
+	lp, err := smtp.ParseLocalpart(alias.LocalpartStr)
+	xcheckf(ctx, err, "parsing alias localpart")
+	return smtp.NewAddress(lp, alias.Domain)
+}
+
+// AliasMemberSave saves the delivery preferences of an address of the account
+// that is a member of the alias.
+func (Account) AliasMemberSave(ctx context.Context, aliasAddress, memberAddress string, member config.AliasMember) {
+	addr := xaliasMember(ctx, aliasAddress, memberAddress)
+	err := admin.AliasMemberSave(ctx, addr, memberAddress, member)
+	xcheckf(ctx, err, "saving member preferences")
+}
+
+// AliasLeave removes an address of the account from the members of the alias.
+func (Account) AliasLeave(ctx context.Context, aliasAddress, memberAddress string) {
+	addr := xaliasMember(ctx, aliasAddress, memberAddress)
+	err := admin.AliasAddressesRemove(ctx, addr, []string{memberAddress})
+	xcheckf(ctx, err, "leaving alias")
+}
+
+// OwnedAlias is an alias with an address of the account as owner.
+type OwnedAlias struct {
+	Address    string   // Alias address.
+	PostPolicy string   // Effective post policy: members, anyone or moderated.
+	Members    []string // Member addresses.
+	Owners     []string
+}
+
+// AliasesOwned returns the aliases that have an address of the account as owner.
+func (Account) AliasesOwned(ctx context.Context) []OwnedAlias {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	accConf, _ := mox.Conf.Account(reqInfo.AccountName)
+	l := []OwnedAlias{}
+	for _, aliasAddress := range accConf.OwnedAliases {
+		_, alias, ok := mox.Conf.AccountDestination(aliasAddress)
+		if !ok || alias == nil {
+			continue
+		}
+		l = append(l, OwnedAlias{aliasAddress, alias.EffectivePostPolicy(), alias.Addresses, alias.Owners})
+	}
+	return l
+}
+
+// AliasOwnerMembersAdd adds member addresses to an alias owned by the account.
+// Members must be addresses of local accounts.
+func (Account) AliasOwnerMembersAdd(ctx context.Context, aliasAddress string, addresses []string) {
+	addr := xaliasOwned(ctx, aliasAddress)
+	err := admin.AliasAddressesAdd(ctx, addr, addresses)
+	xcheckf(ctx, err, "adding members")
+}
+
+// AliasOwnerMembersRemove removes member addresses from an alias owned by the
+// account.
+func (Account) AliasOwnerMembersRemove(ctx context.Context, aliasAddress string, addresses []string) {
+	addr := xaliasOwned(ctx, aliasAddress)
+	err := admin.AliasAddressesRemove(ctx, addr, addresses)
+	xcheckf(ctx, err, "removing members")
+}
+
 // ImportAbort aborts an import that is in progress. If the import exists and isn't
 // finished, no changes will have been made by the import.
 func (Account) ImportAbort(ctx context.Context, importToken string) error {
+	xcheckFeature(ctx, config.WebFeatureImport)
 	req := importAbortRequest{importToken, make(chan error)}
 	importers.Abort <- req
 	return <-req.Response
@@ -575,12 +997,20 @@ func (Account) SuppressionRemove(ctx context.Context, address string) {
 // the Authorization header in HTTP requests. Events specifies the outgoing events
 // to be delivered, or all if empty/nil.
 func (Account) OutgoingWebhookSave(ctx context.Context, url, authorization string, events []string) {
+	xcheckFeature(ctx, config.WebFeatureWebhooks)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	err := admin.AccountSave(ctx, reqInfo.AccountName, func(acc *config.Account) {
 		if url == "" {
 			acc.OutgoingWebhook = nil
 		} else {
-			acc.OutgoingWebhook = &config.OutgoingWebhook{URL: url, Authorization: authorization, Events: events}
+			// Keep settings that are only configurable in the config file.
+			var signingKey string
+			var transcript bool
+			if acc.OutgoingWebhook != nil {
+				signingKey = acc.OutgoingWebhook.SigningKey
+				transcript = acc.OutgoingWebhook.Transcript
+			}
+			acc.OutgoingWebhook = &config.OutgoingWebhook{URL: url, Authorization: authorization, Events: events, SigningKey: signingKey, Transcript: transcript}
 		}
 	})
 	xcheckf(ctx, err, "saving account outgoing webhook")
@@ -590,6 +1020,7 @@ func (Account) OutgoingWebhookSave(ctx context.Context, url, authorization strin
 // authorization. If the HTTP request is made this call will succeed also for
 // non-2xx HTTP status codes.
 func (Account) OutgoingWebhookTest(ctx context.Context, urlStr, authorization string, data webhook.Outgoing) (code int, response string, errmsg string) {
+	xcheckFeature(ctx, config.WebFeatureWebhooks)
 	log := pkglog.WithContext(ctx)
 
 	xvalidURL(ctx, urlStr)
@@ -602,7 +1033,7 @@ func (Account) OutgoingWebhookTest(ctx context.Context, urlStr, authorization st
 	err := enc.Encode(data)
 	xcheckf(ctx, err, "encoding outgoing webhook data")
 
-	code, response, err = queue.HookPost(ctx, log, 1, 1, urlStr, authorization, b.String())
+	code, response, err = queue.HookPost(ctx, log, 1, 1, urlStr, authorization, "", b.String())
 	if err != nil {
 		errmsg = err.Error()
 	}
@@ -614,12 +1045,17 @@ func (Account) OutgoingWebhookTest(ctx context.Context, urlStr, authorization st
 // empty, the webhook is disabled. If authorization is not empty, it is used in
 // the Authorization header in requests.
 func (Account) IncomingWebhookSave(ctx context.Context, url, authorization string) {
+	xcheckFeature(ctx, config.WebFeatureWebhooks)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	err := admin.AccountSave(ctx, reqInfo.AccountName, func(acc *config.Account) {
 		if url == "" {
 			acc.IncomingWebhook = nil
 		} else {
-			acc.IncomingWebhook = &config.IncomingWebhook{URL: url, Authorization: authorization}
+			var signingKey string
+			if acc.IncomingWebhook != nil {
+				signingKey = acc.IncomingWebhook.SigningKey
+			}
+			acc.IncomingWebhook = &config.IncomingWebhook{URL: url, Authorization: authorization, SigningKey: signingKey}
 		}
 	})
 	xcheckf(ctx, err, "saving account incoming webhook")
@@ -637,6 +1073,7 @@ func xvalidURL(ctx context.Context, s string) {
 // with optional authorization header. If the HTTP call is made, this function
 // returns non-error regardless of HTTP status code.
 func (Account) IncomingWebhookTest(ctx context.Context, urlStr, authorization string, data webhook.Incoming) (code int, response string, errmsg string) {
+	xcheckFeature(ctx, config.WebFeatureWebhooks)
 	log := pkglog.WithContext(ctx)
 
 	xvalidURL(ctx, urlStr)
@@ -648,7 +1085,7 @@ func (Account) IncomingWebhookTest(ctx context.Context, urlStr, authorization st
 	enc.SetIndent("", "\t")
 	err := enc.Encode(data)
 	xcheckf(ctx, err, "encoding incoming webhook data")
-	code, response, err = queue.HookPost(ctx, log, 1, 1, urlStr, authorization, b.String())
+	code, response, err = queue.HookPost(ctx, log, 1, 1, urlStr, authorization, "", b.String())
 	if err != nil {
 		errmsg = err.Error()
 	}
@@ -720,12 +1157,211 @@ func (Account) RejectsSave(ctx context.Context, mailbox string, keep bool) {
 	xcheckf(ctx, err, "saving account rejects settings")
 }
 
+// ScreeningSave saves the screening settings for first-time senders. A nil value
+// disables screening.
+func (Account) ScreeningSave(ctx context.Context, screening *config.Screening) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	err := admin.AccountSave(ctx, reqInfo.AccountName, func(acc *config.Account) {
+		acc.Screening = screening
+	})
+	xcheckf(ctx, err, "saving account screening settings")
+}
+
+// CategoriesSave saves the settings for categorizing incoming bulk messages into
+// newsletters, notifications and social updates. A nil value disables
+// categorization, a nil category disables that category.
+func (Account) CategoriesSave(ctx context.Context, categories *config.Categories) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	err := admin.AccountSave(ctx, reqInfo.AccountName, func(acc *config.Account) {
+		acc.Categories = categories
+	})
+	xcheckf(ctx, err, "saving account categories settings")
+}
+
+// SenderList returns the entries of the sender allow and block lists.
+func (Account) SenderList(ctx context.Context) []store.SenderListEntry {
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	l, err := bstore.QueryDB[store.SenderListEntry](ctx, acc.DB).SortAsc("Pattern").List()
+	xcheckf(ctx, err, "listing sender lists")
+	return l
+}
+
+// SenderListAdd adds an address ("user@example.org"), domain ("example.org") or
+// wildcard domain ("*.example.org", also matching example.org) to the sender
+// lists, with action "allow" (accept without junk filtering if the message From
+// address is verified), "reject" (reject during SMTP) or "discard" (accept
+// during SMTP, but don't deliver). For a pattern already present, the action is
+// changed.
+func (Account) SenderListAdd(ctx context.Context, pattern string, action store.SenderListAction) store.SenderListEntry {
+	log := pkglog.WithContext(ctx)
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	e, err := acc.SenderListSave(log, pattern, action)
+	xcheckuserf(ctx, err, "adding sender list entry")
+	return e
+}
+
+// SenderListRemove removes an entry from the sender lists.
+func (Account) SenderListRemove(ctx context.Context, id int64) {
+	log := pkglog.WithContext(ctx)
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	err := acc.SenderListRemove(log, id)
+	xcheckuserf(ctx, err, "removing sender list entry")
+}
+
+// IdentitiesSave saves the identities for sending messages with webmail. Each
+// identity address must be allowed as message From address for the account.
+func (Account) IdentitiesSave(ctx context.Context, identities []config.Identity) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	for _, ident := range identities {
+		addr, err := smtp.ParseAddress(ident.Address)
+		xcheckuserf(ctx, err, "parsing identity address %q", ident.Address)
+		if ok, _ := mox.AllowMsgFrom(reqInfo.AccountName, addr); !ok {
+			xcheckuserf(ctx, errors.New("address not allowed for account"), "checking identity address %q", ident.Address)
+		}
+	}
+	err := admin.AccountSave(ctx, reqInfo.AccountName, func(acc *config.Account) {
+		acc.Identities = identities
+	})
+	xcheckf(ctx, err, "saving account identities")
+}
+
+// PGPKey is an OpenPGP key of the account, without private key material.
+type PGPKey struct {
+	Fingerprint string
+	KeyID       string
+	Created     time.Time
+	Addresses   []string
+	PublicKey   string // Armored.
+	Private     bool   // Whether the private key is present.
+	Publish     bool   // Whether served in the Web Key Directory.
+}
+
+func pgpKeyInfo(k store.PGPKey) PGPKey {
+	return PGPKey{k.Fingerprint, k.KeyID, k.Created, k.Addresses, k.PublicKey, k.PrivateKey != "", k.Publish}
+}
+
+func xopenAccount(ctx context.Context) (*store.Account, func()) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc, err := store.OpenAccount(log, reqInfo.AccountName, false)
+	xcheckf(ctx, err, "open account")
+	return acc, func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}
+}
+
+// PGPKeys returns the OpenPGP keys of the account.
+func (Account) PGPKeys(ctx context.Context) []PGPKey {
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	keys, err := bstore.QueryDB[store.PGPKey](ctx, acc.DB).SortAsc("Created").List()
+	xcheckf(ctx, err, "listing pgp keys")
+	l := make([]PGPKey, len(keys))
+	for i, k := range keys {
+		l[i] = pgpKeyInfo(k)
+	}
+	return l
+}
+
+// PGPKeyAdd adds an armored OpenPGP key to the account. A public key is used for
+// encrypting messages to, and verifying messages from, the addresses of the key.
+// A private key, typically encrypted with a passphrase, is used for signing and
+// decrypting messages. A private key can be added for a public key that is
+// already present. Keys with EdDSA or Curve25519 algorithms are not supported.
+func (Account) PGPKeyAdd(ctx context.Context, armored string) PGPKey {
+	xcheckFeature(ctx, config.WebFeaturePGP)
+	k, err := store.ParsePGPKey(armored)
+	xcheckuserf(ctx, err, "parsing key")
+
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	err = acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		ek, err := bstore.QueryTx[store.PGPKey](tx).FilterNonzero(store.PGPKey{Fingerprint: k.Fingerprint}).Get()
+		if err == bstore.ErrAbsent {
+			return tx.Insert(&k)
+		} else if err != nil {
+			return err
+		} else if ek.PrivateKey != "" || k.PrivateKey == "" {
+			xcheckuserf(ctx, errors.New("key already present"), "adding key")
+		}
+		ek.PrivateKey = k.PrivateKey
+		k = ek
+		return tx.Update(&k)
+	})
+	xcheckf(ctx, err, "adding key")
+	return pgpKeyInfo(k)
+}
+
+// PGPKeyPublish changes whether a key is served in the OpenPGP Web Key Directory,
+// for the addresses of the key that belong to the account. Only keys with a
+// private key can be published.
+func (Account) PGPKeyPublish(ctx context.Context, fingerprint string, publish bool) {
+	xcheckFeature(ctx, config.WebFeaturePGP)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		k, err := bstore.QueryTx[store.PGPKey](tx).FilterNonzero(store.PGPKey{Fingerprint: fingerprint}).Get()
+		if err == bstore.ErrAbsent {
+			xcheckuserf(ctx, err, "looking up key")
+		} else if err != nil {
+			return err
+		}
+		if publish {
+			if k.PrivateKey == "" {
+				xcheckuserf(ctx, errors.New("only keys with private key can be published"), "checking key")
+			}
+			var ok bool
+			for _, s := range k.Addresses {
+				addr, err := smtp.ParseAddress(s)
+				if err != nil {
+					continue
+				}
+				accName, alias, _, _, err := mox.LookupAddress(addr.Localpart, addr.Domain, false, false, false)
+				if err == nil && alias == nil && accName == reqInfo.AccountName {
+					ok = true
+					break
+				}
+			}
+			if !ok {
+				xcheckuserf(ctx, errors.New("none of the key addresses belong to the account"), "checking key")
+			}
+		}
+		k.Publish = publish
+		return tx.Update(&k)
+	})
+	xcheckf(ctx, err, "saving key")
+}
+
+// PGPKeyRemove removes a key from the account.
+func (Account) PGPKeyRemove(ctx context.Context, fingerprint string) {
+	acc, closeAcc := xopenAccount(ctx)
+	defer closeAcc()
+
+	n, err := bstore.QueryDB[store.PGPKey](ctx, acc.DB).FilterNonzero(store.PGPKey{Fingerprint: fingerprint}).Delete()
+	xcheckf(ctx, err, "removing key")
+	if n == 0 {
+		xcheckuserf(ctx, errors.New("key not found"), "removing key")
+	}
+}
+
 func (Account) TLSPublicKeys(ctx context.Context) ([]store.TLSPublicKey, error) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	return store.TLSPublicKeyList(ctx, reqInfo.AccountName)
 }
 
 func (Account) TLSPublicKeyAdd(ctx context.Context, loginAddress, name string, noIMAPPreauth bool, certPEM string) (store.TLSPublicKey, error) {
+	xcheckFeature(ctx, config.WebFeatureTLSPubKeys)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 
 	block, rest := pem.Decode([]byte(certPEM))
@@ -775,6 +1411,7 @@ func (Account) TLSPublicKeyRemove(ctx context.Context, fingerprint string) error
 }
 
 func (Account) TLSPublicKeyUpdate(ctx context.Context, pubKey store.TLSPublicKey) error {
+	xcheckFeature(ctx, config.WebFeatureTLSPubKeys)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	tpk := xtlspublickey(ctx, reqInfo.AccountName, pubKey.Fingerprint)
 	log := pkglog.WithContext(ctx)
@@ -803,3 +1440,82 @@ func (Account) LoginAttempts(ctx context.Context, limit int) []store.LoginAttemp
 	xcheckf(ctx, err, "listing login attempts")
 	return l
 }
+
+// Session is an active session of the account: a login to the webmail or account
+// web interface, or an authenticated IMAP or submission connection.
+type Session struct {
+	Web          bool   // Web login. Otherwise an IMAP/submission connection. Together with ID, identifies the session.
+	ID           int64  // For revoking.
+	Protocol     string // "webmail", "webaccount", "imap" or "submission". Empty for older web sessions.
+	RemoteIP     string
+	UserAgent    string
+	LoginAddress string
+	Started      time.Time
+	LastActivity time.Time
+	Current      bool // Session of this request.
+}
+
+// Sessions returns the active web sessions and IMAP/submission connections of the
+// account.
+func (Account) Sessions(ctx context.Context) []Session {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	cur, err := store.SessionUse(ctx, log, reqInfo.AccountName, reqInfo.SessionToken, "")
+	xcheckf(ctx, err, "get session")
+
+	lsl, err := store.SessionList(ctx, log, reqInfo.AccountName)
+	xcheckf(ctx, err, "listing sessions")
+	l := []Session{}
+	for _, ls := range lsl {
+		l = append(l, Session{true, ls.ID, ls.Kind, ls.RemoteIP, ls.UserAgent, ls.LoginAddress, ls.Created, ls.LastUsed(), ls.ID == cur.ID})
+	}
+	for _, cs := range mox.Connections.AccountSessions(reqInfo.AccountName) {
+		l = append(l, Session{false, cs.ID, cs.Protocol, cs.RemoteIP, cs.UserAgent, cs.LoginAddress, cs.Started, cs.LastActivity, false})
+	}
+	return l
+}
+
+// SessionRevoke ends a session. A web session is logged out, a connection is
+// closed. Clients with a stored password can log in again, change the password to
+// prevent that.
+func (Account) SessionRevoke(ctx context.Context, web bool, id int64) {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	if web {
+		err := store.SessionRemoveID(ctx, log, reqInfo.AccountName, id)
+		if errors.Is(err, store.ErrSessionUnknown) {
+			xcheckuserf(ctx, err, "revoking session")
+		}
+		xcheckf(ctx, err, "revoking session")
+	} else if mox.Connections.AccountSessionsClose(reqInfo.AccountName, id) == 0 {
+		xcheckuserf(ctx, errors.New("unknown session"), "revoking session")
+	}
+}
+
+// SessionsRevokeAll ends all sessions except the session of this request, and
+// returns the number of sessions ended.
+func (Account) SessionsRevokeAll(ctx context.Context) int {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	cur, err := store.SessionUse(ctx, log, reqInfo.AccountName, reqInfo.SessionToken, "")
+	xcheckf(ctx, err, "get session")
+
+	lsl, err := store.SessionList(ctx, log, reqInfo.AccountName)
+	xcheckf(ctx, err, "listing sessions")
+	var n int
+	for _, ls := range lsl {
+		if ls.ID == cur.ID {
+			continue
+		}
+		err := store.SessionRemoveID(ctx, log, reqInfo.AccountName, ls.ID)
+		if err != nil && !errors.Is(err, store.ErrSessionUnknown) {
+			xcheckf(ctx, err, "revoking session")
+		}
+		n++
+	}
+	n += mox.Connections.AccountSessionsClose(reqInfo.AccountName, 0)
+	return n
+}
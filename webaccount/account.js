@@ -229,6 +229,23 @@ const [dom, style, attr, prop] = (function () {
 // NOTE: GENERATED by github.com/mjl-/sherpats, DO NOT MODIFY
 var api;
 (function (api) {
+	// BounceClass is the classification of a delivery failure, based on the SMTP
+	// codes and response text from the remote server, or from an incoming DSN.
+	let BounceClass;
+	(function (BounceClass) {
+		// Recipient address does not exist or does not accept email anymore. Sending to
+		// it again is pointless.
+		BounceClass["BounceHard"] = "hard";
+		// Temporary condition at the recipient, e.g. a full mailbox, or a remote server
+		// that is unavailable. Delivery may succeed later.
+		BounceClass["BounceSoft"] = "soft";
+		// Our server (its IP or domain) is blocked by the recipient, e.g. due to listing
+		// in a DNS blocklist or bad reputation. Not specific to the recipient address.
+		BounceClass["BounceBlock"] = "block";
+		// Message was rejected due to a policy, e.g. a content filter considering it
+		// spam, failed SPF/DKIM/DMARC, or a message that is too large.
+		BounceClass["BouncePolicy"] = "policy";
+	})(BounceClass = api.BounceClass || (api.BounceClass = {}));
 	// OutgoingEvent is an activity for an outgoing delivery. Either generated by the
 	// queue, or through an incoming DSN (delivery status notification) message.
 	let OutgoingEvent;
@@ -255,6 +272,14 @@ var api;
 		// per-outgoing-message address used for sending.
 		OutgoingEvent["EventUnrecognized"] = "unrecognized";
 	})(OutgoingEvent = api.OutgoingEvent || (api.OutgoingEvent = {}));
+	// SenderListAction is the action for messages from a sender matching an entry
+	// in the sender lists of an account.
+	let SenderListAction;
+	(function (SenderListAction) {
+		SenderListAction["SenderListAllow"] = "allow";
+		SenderListAction["SenderListReject"] = "reject";
+		SenderListAction["SenderListDiscard"] = "discard";
+	})(SenderListAction = api.SenderListAction || (api.SenderListAction = {}));
 	// AuthResult is the result of a login attempt.
 	let AuthResult;
 	(function (AuthResult) {
@@ -265,42 +290,80 @@ var api;
 		AuthResult["AuthBadChannelBinding"] = "badchanbind";
 		AuthResult["AuthBadProtocol"] = "badprotocol";
 		AuthResult["AuthLoginDisabled"] = "logindisabled";
+		AuthResult["AuthLoginLocked"] = "loginlocked";
+		AuthResult["AuthPasswordExpired"] = "passwordexpired";
+		AuthResult["AuthAppPasswordRequired"] = "apppasswordrequired";
+		AuthResult["AuthTOTPRequired"] = "totprequired";
+		AuthResult["AuthTwoFactorSetup"] = "twofactorsetup";
 		AuthResult["AuthError"] = "error";
 		AuthResult["AuthAborted"] = "aborted";
 	})(AuthResult = api.AuthResult || (api.AuthResult = {}));
-	api.structTypes = { "Account": true, "Address": true, "AddressAlias": true, "Alias": true, "AliasAddress": true, "AutomaticJunkFlags": true, "Destination": true, "Domain": true, "ImportProgress": true, "Incoming": true, "IncomingMeta": true, "IncomingWebhook": true, "JunkFilter": true, "LoginAttempt": true, "NameAddress": true, "Outgoing": true, "OutgoingWebhook": true, "Route": true, "Ruleset": true, "Structure": true, "SubjectPass": true, "Suppression": true, "TLSPublicKey": true };
-	api.stringsTypes = { "AuthResult": true, "CSRFToken": true, "Localpart": true, "OutgoingEvent": true };
+	api.structTypes = { "Account": true, "Address": true, "AddressAlias": true, "Alias": true, "AliasAddress": true, "AliasList": true, "AliasMember": true, "AppPassword": true, "AutomaticJunkFlags": true, "Categories": true, "Category": true, "Delegation": true, "Destination": true, "Domain": true, "Identity": true, "ImportProgress": true, "Incoming": true, "IncomingMeta": true, "IncomingWebhook": true, "JunkFilter": true, "JunkReevaluation": true, "LoginAttempt": true, "NameAddress": true, "Outgoing": true, "OutgoingFooter": true, "OutgoingHeaderRules": true, "OutgoingWebhook": true, "OwnedAlias": true, "PGPKey": true, "Passkey": true, "PasskeyAssertion": true, "PasskeyCreateOptions": true, "PasskeyGetOptions": true, "PasswordPolicy": true, "PasswordStatus": true, "Route": true, "Ruleset": true, "Screening": true, "SenderListEntry": true, "Session": true, "Structure": true, "SubjectPass": true, "Suppression": true, "TLSPublicKey": true, "TOTPSetup": true, "TwoFactorStatus": true };
+	api.stringsTypes = { "AuthResult": true, "BounceClass": true, "CSRFToken": true, "Localpart": true, "OutgoingEvent": true, "SenderListAction": true };
 	api.intsTypes = {};
 	api.types = {
-		"Account": { "Name": "Account", "Docs": "", "Fields": [{ "Name": "OutgoingWebhook", "Docs": "", "Typewords": ["nullable", "OutgoingWebhook"] }, { "Name": "IncomingWebhook", "Docs": "", "Typewords": ["nullable", "IncomingWebhook"] }, { "Name": "FromIDLoginAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "KeepRetiredMessagePeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "KeepRetiredWebhookPeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginDisabled", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destinations", "Docs": "", "Typewords": ["{}", "Destination"] }, { "Name": "SubjectPass", "Docs": "", "Typewords": ["SubjectPass"] }, { "Name": "QuotaMessageSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "KeepRejects", "Docs": "", "Typewords": ["bool"] }, { "Name": "AutomaticJunkFlags", "Docs": "", "Typewords": ["AutomaticJunkFlags"] }, { "Name": "JunkFilter", "Docs": "", "Typewords": ["nullable", "JunkFilter"] }, { "Name": "MaxOutgoingMessagesPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxFirstTimeRecipientsPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "NoFirstTimeSenderDelay", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoCustomPassword", "Docs": "", "Typewords": ["bool"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Aliases", "Docs": "", "Typewords": ["[]", "AddressAlias"] }] },
-		"OutgoingWebhook": { "Name": "OutgoingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "Events", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"IncomingWebhook": { "Name": "IncomingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }] },
+		"PasskeyGetOptions": { "Name": "PasskeyGetOptions", "Docs": "", "Fields": [{ "Name": "Challenge", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "RPID", "Docs": "", "Typewords": ["string"] }, { "Name": "Timeout", "Docs": "", "Typewords": ["int32"] }] },
+		"PasskeyAssertion": { "Name": "PasskeyAssertion", "Docs": "", "Fields": [{ "Name": "CredentialID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ClientDataJSON", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "AuthenticatorData", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"PasswordStatus": { "Name": "PasswordStatus", "Docs": "", "Fields": [{ "Name": "Changed", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Expires", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Expired", "Docs": "", "Typewords": ["bool"] }, { "Name": "RecoveryCodes", "Docs": "", "Typewords": ["int32"] }] },
+		"TwoFactorStatus": { "Name": "TwoFactorStatus", "Docs": "", "Fields": [{ "Name": "Enabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "Required", "Docs": "", "Typewords": ["bool"] }, { "Name": "AppPasswords", "Docs": "", "Typewords": ["[]", "AppPassword"] }] },
+		"AppPassword": { "Name": "AppPassword", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Protocols", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "IPRanges", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "LastUsed", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastIP", "Docs": "", "Typewords": ["string"] }] },
+		"TOTPSetup": { "Name": "TOTPSetup", "Docs": "", "Fields": [{ "Name": "Secret", "Docs": "", "Typewords": ["string"] }, { "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "QRCodePNG", "Docs": "", "Typewords": ["string"] }] },
+		"Passkey": { "Name": "Passkey", "Docs": "", "Fields": [{ "Name": "CredentialID", "Docs": "", "Typewords": ["string"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "SignCount", "Docs": "", "Typewords": ["uint32"] }, { "Name": "LastUsed", "Docs": "", "Typewords": ["timestamp"] }] },
+		"PasskeyCreateOptions": { "Name": "PasskeyCreateOptions", "Docs": "", "Fields": [{ "Name": "Challenge", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "RPID", "Docs": "", "Typewords": ["string"] }, { "Name": "RPName", "Docs": "", "Typewords": ["string"] }, { "Name": "UserID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "UserName", "Docs": "", "Typewords": ["string"] }, { "Name": "Algorithms", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "ExcludeCredentials", "Docs": "", "Typewords": ["[]", "nullable", "string"] }, { "Name": "Timeout", "Docs": "", "Typewords": ["int32"] }] },
+		"Account": { "Name": "Account", "Docs": "", "Fields": [{ "Name": "OutgoingWebhook", "Docs": "", "Typewords": ["nullable", "OutgoingWebhook"] }, { "Name": "IncomingWebhook", "Docs": "", "Typewords": ["nullable", "IncomingWebhook"] }, { "Name": "FromIDLoginAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "KeepRetiredMessagePeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "KeepRetiredWebhookPeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginDisabled", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destinations", "Docs": "", "Typewords": ["{}", "Destination"] }, { "Name": "SubjectPass", "Docs": "", "Typewords": ["SubjectPass"] }, { "Name": "QuotaMessageSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "KeepRejects", "Docs": "", "Typewords": ["bool"] }, { "Name": "AutomaticJunkFlags", "Docs": "", "Typewords": ["AutomaticJunkFlags"] }, { "Name": "JunkFilter", "Docs": "", "Typewords": ["nullable", "JunkFilter"] }, { "Name": "JunkReevaluation", "Docs": "", "Typewords": ["nullable", "JunkReevaluation"] }, { "Name": "Screening", "Docs": "", "Typewords": ["nullable", "Screening"] }, { "Name": "Categories", "Docs": "", "Typewords": ["nullable", "Categories"] }, { "Name": "MaxOutgoingMessagesPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxFirstTimeRecipientsPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "NoFirstTimeSenderDelay", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoCustomPassword", "Docs": "", "Typewords": ["bool"] }, { "Name": "PasswordPolicy", "Docs": "", "Typewords": ["nullable", "PasswordPolicy"] }, { "Name": "RequireTwoFactor", "Docs": "", "Typewords": ["bool"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "OutgoingHeaderRules", "Docs": "", "Typewords": ["nullable", "OutgoingHeaderRules"] }, { "Name": "OutgoingFooter", "Docs": "", "Typewords": ["nullable", "OutgoingFooter"] }, { "Name": "Identities", "Docs": "", "Typewords": ["[]", "Identity"] }, { "Name": "Delegations", "Docs": "", "Typewords": ["[]", "Delegation"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Aliases", "Docs": "", "Typewords": ["[]", "AddressAlias"] }, { "Name": "OwnedAliases", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"OutgoingWebhook": { "Name": "OutgoingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "Events", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SigningKey", "Docs": "", "Typewords": ["string"] }, { "Name": "Transcript", "Docs": "", "Typewords": ["bool"] }] },
+		"IncomingWebhook": { "Name": "IncomingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "SigningKey", "Docs": "", "Typewords": ["string"] }] },
 		"Destination": { "Name": "Destination", "Docs": "", "Fields": [{ "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Rulesets", "Docs": "", "Typewords": ["[]", "Ruleset"] }, { "Name": "SMTPError", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageAuthRequiredSMTPError", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }] },
 		"Ruleset": { "Name": "Ruleset", "Docs": "", "Fields": [{ "Name": "SMTPMailFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "HeadersRegexp", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListAllowDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "AcceptRejectsToMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ListAllowDNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
 		"Domain": { "Name": "Domain", "Docs": "", "Fields": [{ "Name": "ASCII", "Docs": "", "Typewords": ["string"] }, { "Name": "Unicode", "Docs": "", "Typewords": ["string"] }] },
 		"SubjectPass": { "Name": "SubjectPass", "Docs": "", "Fields": [{ "Name": "Period", "Docs": "", "Typewords": ["int64"] }] },
 		"AutomaticJunkFlags": { "Name": "AutomaticJunkFlags", "Docs": "", "Fields": [{ "Name": "Enabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "JunkMailboxRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "NeutralMailboxRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "NotJunkMailboxRegexp", "Docs": "", "Typewords": ["string"] }] },
-		"JunkFilter": { "Name": "JunkFilter", "Docs": "", "Fields": [{ "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "Onegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "Twograms", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "MaxPower", "Docs": "", "Typewords": ["float64"] }, { "Name": "TopWords", "Docs": "", "Typewords": ["int32"] }, { "Name": "IgnoreWords", "Docs": "", "Typewords": ["float64"] }, { "Name": "RareWords", "Docs": "", "Typewords": ["int32"] }] },
+		"JunkFilter": { "Name": "JunkFilter", "Docs": "", "Fields": [{ "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "TagThreshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "JunkThreshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "HighAction", "Docs": "", "Typewords": ["string"] }, { "Name": "Onegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "Twograms", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "MaxPower", "Docs": "", "Typewords": ["float64"] }, { "Name": "TopWords", "Docs": "", "Typewords": ["int32"] }, { "Name": "IgnoreWords", "Docs": "", "Typewords": ["float64"] }, { "Name": "RareWords", "Docs": "", "Typewords": ["int32"] }] },
+		"JunkReevaluation": { "Name": "JunkReevaluation", "Docs": "", "Fields": [{ "Name": "Window", "Docs": "", "Typewords": ["int64"] }, { "Name": "Sender", "Docs": "", "Typewords": ["bool"] }, { "Name": "DNSBL", "Docs": "", "Typewords": ["bool"] }, { "Name": "Spamtrap", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notify", "Docs": "", "Typewords": ["bool"] }] },
+		"Screening": { "Name": "Screening", "Docs": "", "Fields": [{ "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }] },
+		"Categories": { "Name": "Categories", "Docs": "", "Fields": [{ "Name": "Newsletters", "Docs": "", "Typewords": ["nullable", "Category"] }, { "Name": "Notifications", "Docs": "", "Typewords": ["nullable", "Category"] }, { "Name": "Social", "Docs": "", "Typewords": ["nullable", "Category"] }] },
+		"Category": { "Name": "Category", "Docs": "", "Fields": [{ "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Domains", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"PasswordPolicy": { "Name": "PasswordPolicy", "Docs": "", "Fields": [{ "Name": "MinLength", "Docs": "", "Typewords": ["int32"] }, { "Name": "MinEntropy", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAge", "Docs": "", "Typewords": ["int64"] }, { "Name": "LockoutFailures", "Docs": "", "Typewords": ["int32"] }, { "Name": "LockoutPeriod", "Docs": "", "Typewords": ["int64"] }] },
 		"Route": { "Name": "Route", "Docs": "", "Fields": [{ "Name": "FromDomain", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ToDomain", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MinimumAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "FromDomainASCII", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ToDomainASCII", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"AddressAlias": { "Name": "AddressAlias", "Docs": "", "Fields": [{ "Name": "SubscriptionAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Alias", "Docs": "", "Typewords": ["Alias"] }, { "Name": "MemberAddresses", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"Alias": { "Name": "Alias", "Docs": "", "Fields": [{ "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PostPublic", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListMembers", "Docs": "", "Typewords": ["bool"] }, { "Name": "AllowMsgFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "LocalpartStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ParsedAddresses", "Docs": "", "Typewords": ["[]", "AliasAddress"] }] },
-		"AliasAddress": { "Name": "AliasAddress", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["Address"] }, { "Name": "AccountName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destination", "Docs": "", "Typewords": ["Destination"] }] },
+		"OutgoingHeaderRules": { "Name": "OutgoingHeaderRules", "Docs": "", "Fields": [{ "Name": "Remove", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "FromDisplayName", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Add", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"OutgoingFooter": { "Name": "OutgoingFooter", "Docs": "", "Fields": [{ "Name": "Text", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HTML", "Docs": "", "Typewords": ["string"] }] },
+		"Identity": { "Name": "Identity", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "DisplayName", "Docs": "", "Typewords": ["string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignatureHTML", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyQuoting", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Default", "Docs": "", "Typewords": ["bool"] }] },
+		"Delegation": { "Name": "Delegation", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Write", "Docs": "", "Typewords": ["bool"] }, { "Name": "Send", "Docs": "", "Typewords": ["string"] }] },
+		"AddressAlias": { "Name": "AddressAlias", "Docs": "", "Fields": [{ "Name": "SubscriptionAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Alias", "Docs": "", "Typewords": ["Alias"] }, { "Name": "MemberAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Member", "Docs": "", "Typewords": ["AliasMember"] }] },
+		"Alias": { "Name": "Alias", "Docs": "", "Fields": [{ "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PostPublic", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListMembers", "Docs": "", "Typewords": ["bool"] }, { "Name": "AllowMsgFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "PostPolicy", "Docs": "", "Typewords": ["string"] }, { "Name": "Owners", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ListHeaders", "Docs": "", "Typewords": ["bool"] }, { "Name": "Members", "Docs": "", "Typewords": ["{}", "AliasMember"] }, { "Name": "List", "Docs": "", "Typewords": ["nullable", "AliasList"] }, { "Name": "LocalpartStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ParsedAddresses", "Docs": "", "Typewords": ["[]", "AliasAddress"] }, { "Name": "ParsedOwners", "Docs": "", "Typewords": ["[]", "AliasAddress"] }] },
+		"AliasMember": { "Name": "AliasMember", "Docs": "", "Fields": [{ "Name": "NoDelivery", "Docs": "", "Typewords": ["bool"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }] },
+		"AliasList": { "Name": "AliasList", "Docs": "", "Fields": [{ "Name": "Subscribe", "Docs": "", "Typewords": ["bool"] }, { "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "BounceLimit", "Docs": "", "Typewords": ["int32"] }] },
+		"AliasAddress": { "Name": "AliasAddress", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["Address"] }, { "Name": "AccountName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destination", "Docs": "", "Typewords": ["Destination"] }, { "Name": "Member", "Docs": "", "Typewords": ["AliasMember"] }] },
 		"Address": { "Name": "Address", "Docs": "", "Fields": [{ "Name": "Localpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }] },
-		"Suppression": { "Name": "Suppression", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "BaseAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "OriginalAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Manual", "Docs": "", "Typewords": ["bool"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }] },
+		"Suppression": { "Name": "Suppression", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "BaseAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "OriginalAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Manual", "Docs": "", "Typewords": ["bool"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }, { "Name": "BounceClass", "Docs": "", "Typewords": ["BounceClass"] }] },
+		"OwnedAlias": { "Name": "OwnedAlias", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "PostPolicy", "Docs": "", "Typewords": ["string"] }, { "Name": "Members", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Owners", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"ImportProgress": { "Name": "ImportProgress", "Docs": "", "Fields": [{ "Name": "Token", "Docs": "", "Typewords": ["string"] }] },
-		"Outgoing": { "Name": "Outgoing", "Docs": "", "Fields": [{ "Name": "Version", "Docs": "", "Typewords": ["int32"] }, { "Name": "Event", "Docs": "", "Typewords": ["OutgoingEvent"] }, { "Name": "DSN", "Docs": "", "Typewords": ["bool"] }, { "Name": "Suppressing", "Docs": "", "Typewords": ["bool"] }, { "Name": "QueueMsgID", "Docs": "", "Typewords": ["int64"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "WebhookQueued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SMTPCode", "Docs": "", "Typewords": ["int32"] }, { "Name": "SMTPEnhancedCode", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }] },
+		"Outgoing": { "Name": "Outgoing", "Docs": "", "Fields": [{ "Name": "Version", "Docs": "", "Typewords": ["int32"] }, { "Name": "Event", "Docs": "", "Typewords": ["OutgoingEvent"] }, { "Name": "DSN", "Docs": "", "Typewords": ["bool"] }, { "Name": "Suppressing", "Docs": "", "Typewords": ["bool"] }, { "Name": "QueueMsgID", "Docs": "", "Typewords": ["int64"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "WebhookQueued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SMTPCode", "Docs": "", "Typewords": ["int32"] }, { "Name": "SMTPEnhancedCode", "Docs": "", "Typewords": ["string"] }, { "Name": "BounceClass", "Docs": "", "Typewords": ["BounceClass"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "Recipient", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "RemoteMTA", "Docs": "", "Typewords": ["string"] }, { "Name": "SMTPResponse", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "TLS", "Docs": "", "Typewords": ["string"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["bool"] }, { "Name": "DANE", "Docs": "", "Typewords": ["bool"] }, { "Name": "Transcript", "Docs": "", "Typewords": ["string"] }] },
 		"Incoming": { "Name": "Incoming", "Docs": "", "Fields": [{ "Name": "Version", "Docs": "", "Typewords": ["int32"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "NameAddress"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "NameAddress"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "NameAddress"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "NameAddress"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "NameAddress"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "References", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Date", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }, { "Name": "HTML", "Docs": "", "Typewords": ["string"] }, { "Name": "Structure", "Docs": "", "Typewords": ["Structure"] }, { "Name": "Meta", "Docs": "", "Typewords": ["IncomingMeta"] }] },
 		"NameAddress": { "Name": "NameAddress", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }] },
 		"Structure": { "Name": "Structure", "Docs": "", "Fields": [{ "Name": "ContentType", "Docs": "", "Typewords": ["string"] }, { "Name": "ContentTypeParams", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "ContentID", "Docs": "", "Typewords": ["string"] }, { "Name": "ContentDisposition", "Docs": "", "Typewords": ["string"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DecodedSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "Parts", "Docs": "", "Typewords": ["[]", "Structure"] }] },
 		"IncomingMeta": { "Name": "IncomingMeta", "Docs": "", "Fields": [{ "Name": "MsgID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MsgFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "RcptTo", "Docs": "", "Typewords": ["string"] }, { "Name": "DKIMVerifiedDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Automated", "Docs": "", "Typewords": ["bool"] }] },
+		"SenderListEntry": { "Name": "SenderListEntry", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Pattern", "Docs": "", "Typewords": ["string"] }, { "Name": "Action", "Docs": "", "Typewords": ["SenderListAction"] }] },
+		"PGPKey": { "Name": "PGPKey", "Docs": "", "Fields": [{ "Name": "Fingerprint", "Docs": "", "Typewords": ["string"] }, { "Name": "KeyID", "Docs": "", "Typewords": ["string"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PublicKey", "Docs": "", "Typewords": ["string"] }, { "Name": "Private", "Docs": "", "Typewords": ["bool"] }, { "Name": "Publish", "Docs": "", "Typewords": ["bool"] }] },
 		"TLSPublicKey": { "Name": "TLSPublicKey", "Docs": "", "Fields": [{ "Name": "Fingerprint", "Docs": "", "Typewords": ["string"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Type", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "NoIMAPPreauth", "Docs": "", "Typewords": ["bool"] }, { "Name": "CertDER", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }] },
 		"LoginAttempt": { "Name": "LoginAttempt", "Docs": "", "Fields": [{ "Name": "Key", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Last", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "First", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Count", "Docs": "", "Typewords": ["int64"] }, { "Name": "AccountName", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "LocalIP", "Docs": "", "Typewords": ["string"] }, { "Name": "TLS", "Docs": "", "Typewords": ["string"] }, { "Name": "TLSPubKeyFingerprint", "Docs": "", "Typewords": ["string"] }, { "Name": "Protocol", "Docs": "", "Typewords": ["string"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "AuthMech", "Docs": "", "Typewords": ["string"] }, { "Name": "Result", "Docs": "", "Typewords": ["AuthResult"] }] },
+		"Session": { "Name": "Session", "Docs": "", "Fields": [{ "Name": "Web", "Docs": "", "Typewords": ["bool"] }, { "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Protocol", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Started", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastActivity", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Current", "Docs": "", "Typewords": ["bool"] }] },
 		"CSRFToken": { "Name": "CSRFToken", "Docs": "", "Values": null },
 		"Localpart": { "Name": "Localpart", "Docs": "", "Values": null },
+		"BounceClass": { "Name": "BounceClass", "Docs": "", "Values": [{ "Name": "BounceHard", "Value": "hard", "Docs": "" }, { "Name": "BounceSoft", "Value": "soft", "Docs": "" }, { "Name": "BounceBlock", "Value": "block", "Docs": "" }, { "Name": "BouncePolicy", "Value": "policy", "Docs": "" }] },
 		"OutgoingEvent": { "Name": "OutgoingEvent", "Docs": "", "Values": [{ "Name": "EventDelivered", "Value": "delivered", "Docs": "" }, { "Name": "EventSuppressed", "Value": "suppressed", "Docs": "" }, { "Name": "EventDelayed", "Value": "delayed", "Docs": "" }, { "Name": "EventFailed", "Value": "failed", "Docs": "" }, { "Name": "EventRelayed", "Value": "relayed", "Docs": "" }, { "Name": "EventExpanded", "Value": "expanded", "Docs": "" }, { "Name": "EventCanceled", "Value": "canceled", "Docs": "" }, { "Name": "EventUnrecognized", "Value": "unrecognized", "Docs": "" }] },
-		"AuthResult": { "Name": "AuthResult", "Docs": "", "Values": [{ "Name": "AuthSuccess", "Value": "ok", "Docs": "" }, { "Name": "AuthBadUser", "Value": "baduser", "Docs": "" }, { "Name": "AuthBadPassword", "Value": "badpassword", "Docs": "" }, { "Name": "AuthBadCredentials", "Value": "badcreds", "Docs": "" }, { "Name": "AuthBadChannelBinding", "Value": "badchanbind", "Docs": "" }, { "Name": "AuthBadProtocol", "Value": "badprotocol", "Docs": "" }, { "Name": "AuthLoginDisabled", "Value": "logindisabled", "Docs": "" }, { "Name": "AuthError", "Value": "error", "Docs": "" }, { "Name": "AuthAborted", "Value": "aborted", "Docs": "" }] },
+		"SenderListAction": { "Name": "SenderListAction", "Docs": "", "Values": [{ "Name": "SenderListAllow", "Value": "allow", "Docs": "" }, { "Name": "SenderListReject", "Value": "reject", "Docs": "" }, { "Name": "SenderListDiscard", "Value": "discard", "Docs": "" }] },
+		"AuthResult": { "Name": "AuthResult", "Docs": "", "Values": [{ "Name": "AuthSuccess", "Value": "ok", "Docs": "" }, { "Name": "AuthBadUser", "Value": "baduser", "Docs": "" }, { "Name": "AuthBadPassword", "Value": "badpassword", "Docs": "" }, { "Name": "AuthBadCredentials", "Value": "badcreds", "Docs": "" }, { "Name": "AuthBadChannelBinding", "Value": "badchanbind", "Docs": "" }, { "Name": "AuthBadProtocol", "Value": "badprotocol", "Docs": "" }, { "Name": "AuthLoginDisabled", "Value": "logindisabled", "Docs": "" }, { "Name": "AuthLoginLocked", "Value": "loginlocked", "Docs": "" }, { "Name": "AuthPasswordExpired", "Value": "passwordexpired", "Docs": "" }, { "Name": "AuthAppPasswordRequired", "Value": "apppasswordrequired", "Docs": "" }, { "Name": "AuthTOTPRequired", "Value": "totprequired", "Docs": "" }, { "Name": "AuthTwoFactorSetup", "Value": "twofactorsetup", "Docs": "" }, { "Name": "AuthError", "Value": "error", "Docs": "" }, { "Name": "AuthAborted", "Value": "aborted", "Docs": "" }] },
 	};
 	api.parser = {
+		PasskeyGetOptions: (v) => api.parse("PasskeyGetOptions", v),
+		PasskeyAssertion: (v) => api.parse("PasskeyAssertion", v),
+		PasswordStatus: (v) => api.parse("PasswordStatus", v),
+		TwoFactorStatus: (v) => api.parse("TwoFactorStatus", v),
+		AppPassword: (v) => api.parse("AppPassword", v),
+		TOTPSetup: (v) => api.parse("TOTPSetup", v),
+		Passkey: (v) => api.parse("Passkey", v),
+		PasskeyCreateOptions: (v) => api.parse("PasskeyCreateOptions", v),
 		Account: (v) => api.parse("Account", v),
 		OutgoingWebhook: (v) => api.parse("OutgoingWebhook", v),
 		IncomingWebhook: (v) => api.parse("IncomingWebhook", v),
@@ -310,23 +373,40 @@ var api;
 		SubjectPass: (v) => api.parse("SubjectPass", v),
 		AutomaticJunkFlags: (v) => api.parse("AutomaticJunkFlags", v),
 		JunkFilter: (v) => api.parse("JunkFilter", v),
+		JunkReevaluation: (v) => api.parse("JunkReevaluation", v),
+		Screening: (v) => api.parse("Screening", v),
+		Categories: (v) => api.parse("Categories", v),
+		Category: (v) => api.parse("Category", v),
+		PasswordPolicy: (v) => api.parse("PasswordPolicy", v),
 		Route: (v) => api.parse("Route", v),
+		OutgoingHeaderRules: (v) => api.parse("OutgoingHeaderRules", v),
+		OutgoingFooter: (v) => api.parse("OutgoingFooter", v),
+		Identity: (v) => api.parse("Identity", v),
+		Delegation: (v) => api.parse("Delegation", v),
 		AddressAlias: (v) => api.parse("AddressAlias", v),
 		Alias: (v) => api.parse("Alias", v),
+		AliasMember: (v) => api.parse("AliasMember", v),
+		AliasList: (v) => api.parse("AliasList", v),
 		AliasAddress: (v) => api.parse("AliasAddress", v),
 		Address: (v) => api.parse("Address", v),
 		Suppression: (v) => api.parse("Suppression", v),
+		OwnedAlias: (v) => api.parse("OwnedAlias", v),
 		ImportProgress: (v) => api.parse("ImportProgress", v),
 		Outgoing: (v) => api.parse("Outgoing", v),
 		Incoming: (v) => api.parse("Incoming", v),
 		NameAddress: (v) => api.parse("NameAddress", v),
 		Structure: (v) => api.parse("Structure", v),
 		IncomingMeta: (v) => api.parse("IncomingMeta", v),
+		SenderListEntry: (v) => api.parse("SenderListEntry", v),
+		PGPKey: (v) => api.parse("PGPKey", v),
 		TLSPublicKey: (v) => api.parse("TLSPublicKey", v),
 		LoginAttempt: (v) => api.parse("LoginAttempt", v),
+		Session: (v) => api.parse("Session", v),
 		CSRFToken: (v) => api.parse("CSRFToken", v),
 		Localpart: (v) => api.parse("Localpart", v),
+		BounceClass: (v) => api.parse("BounceClass", v),
 		OutgoingEvent: (v) => api.parse("OutgoingEvent", v),
+		SenderListAction: (v) => api.parse("SenderListAction", v),
 		AuthResult: (v) => api.parse("AuthResult", v),
 	};
 	// Account exports web API functions for the account web interface. All its
@@ -364,12 +444,44 @@ var api;
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Login returns a session token for the credentials, or fails with error code
-		// "user:badLogin". Call LoginPrep to get a loginToken.
-		async Login(loginToken, username, password) {
+		// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+		// authentication is enabled, totp must be a code from the authenticator app,
+		// otherwise the login fails with error code "user:totpRequired".
+		async Login(loginToken, username, password, totp) {
 			const fn = "Login";
-			const paramTypes = [["string"], ["string"], ["string"]];
+			const paramTypes = [["string"], ["string"], ["string"], ["string"]];
 			const returnTypes = [["CSRFToken"]];
-			const params = [loginToken, username, password];
+			const params = [loginToken, username, password, totp];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLoginStart returns the parameters for the browser to login with a
+		// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+		// of the browser.
+		async PasskeyLoginStart(loginToken) {
+			const fn = "PasskeyLoginStart";
+			const paramTypes = [["string"]];
+			const returnTypes = [["PasskeyGetOptions"]];
+			const params = [loginToken];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLogin returns a session token for a passkey login started with
+		// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+		// password, the passkey must verify the user, e.g. with a PIN. With username and
+		// password, the passkey is the second factor.
+		async PasskeyLogin(loginToken, username, password, assertion) {
+			const fn = "PasskeyLogin";
+			const paramTypes = [["string"], ["string"], ["string"], ["PasskeyAssertion"]];
+			const returnTypes = [["CSRFToken"]];
+			const params = [loginToken, username, password, assertion];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// OIDCEnabled returns whether logging in through an OpenID Connect identity
+		// provider is possible, by sending the browser to "oidc/login".
+		async OIDCEnabled() {
+			const fn = "OIDCEnabled";
+			const paramTypes = [];
+			const returnTypes = [["bool"]];
+			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Logout invalidates the session token.
@@ -394,7 +506,8 @@ var api;
 		// Sessions are not interrupted, and will keep working. New login attempts must use
 		// the new password.
 		// 
-		// Password must be at least 8 characters.
+		// Password must be at least 8 characters, and meet the password policy of the
+		// account, if any.
 		// 
 		// Setting a user-supplied password is not allowed if NoCustomPassword is set
 		// for the account.
@@ -414,13 +527,127 @@ var api;
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// PasswordStatus returns the state of the password, e.g. whether it has expired,
+		// and the number of unused recovery codes.
+		async PasswordStatus() {
+			const fn = "PasswordStatus";
+			const paramTypes = [];
+			const returnTypes = [["PasswordStatus"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RecoveryCodesGenerate replaces any existing recovery codes with new codes and
+		// returns them. Each code can be used once instead of a two-factor authentication
+		// code when logging in to the web interfaces.
+		async RecoveryCodesGenerate() {
+			const fn = "RecoveryCodesGenerate";
+			const paramTypes = [];
+			const returnTypes = [["[]", "string"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TwoFactorStatus returns whether two-factor authentication is enabled or
+		// required, and the app passwords.
+		async TwoFactorStatus() {
+			const fn = "TwoFactorStatus";
+			const paramTypes = [];
+			const returnTypes = [["TwoFactorStatus"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TOTPSetupStart starts enabling two-factor authentication with a time-based
+		// one-time password (TOTP), returning the parameters for the authenticator app.
+		// Call TOTPSetupConfirm with a code from the app to enable it.
+		async TOTPSetupStart() {
+			const fn = "TOTPSetupStart";
+			const paramTypes = [];
+			const returnTypes = [["TOTPSetup"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TOTPSetupConfirm enables two-factor authentication after verifying a code
+		// from the authenticator app. Logins to the web interfaces then require a code,
+		// and other protocols, like IMAP and SMTP, require app passwords.
+		async TOTPSetupConfirm(code) {
+			const fn = "TOTPSetupConfirm";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [code];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TOTPRemove disables two-factor authentication, after verifying a current code.
+		// Not possible when the admin requires two-factor authentication.
+		async TOTPRemove(code) {
+			const fn = "TOTPRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [code];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AppPasswordAdd adds an app password for use by a single application, e.g. an
+		// IMAP/SMTP client, for the given protocols: "imap", "submission", "webapi",
+		// "caldav", "carddav". If ipRanges is non-empty, the password can only be used
+		// from those networks, in CIDR notation or single IPs. The generated password is
+		// returned, it cannot be retrieved later.
+		async AppPasswordAdd(name, protocols, ipRanges) {
+			const fn = "AppPasswordAdd";
+			const paramTypes = [["string"], ["[]", "string"], ["[]", "string"]];
+			const returnTypes = [["string"]];
+			const params = [name, protocols, ipRanges];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AppPasswordRemove removes an app password.
+		async AppPasswordRemove(id) {
+			const fn = "AppPasswordRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [id];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Passkeys returns the passkeys registered for the account.
+		async Passkeys() {
+			const fn = "Passkeys";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Passkey"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyRegisterStart starts registration of a new passkey, returning the
+		// parameters for the browser. Complete the registration with PasskeyRegister.
+		async PasskeyRegisterStart() {
+			const fn = "PasskeyRegisterStart";
+			const paramTypes = [];
+			const returnTypes = [["PasskeyCreateOptions"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyRegister completes registration of a passkey with the response from the
+		// browser. Name describes the passkey, e.g. the device. Logging in with the
+		// passkey starts a session for the current login address.
+		async PasskeyRegister(name, clientDataJSON, attestationObject) {
+			const fn = "PasskeyRegister";
+			const paramTypes = [["string"], ["nullable", "string"], ["nullable", "string"]];
+			const returnTypes = [["Passkey"]];
+			const params = [name, clientDataJSON, attestationObject];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyRemove removes a passkey.
+		async PasskeyRemove(credentialID) {
+			const fn = "PasskeyRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [credentialID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// Account returns information about the account.
 		// StorageUsed is the sum of the sizes of all messages, in bytes.
 		// StorageLimit is the maximum storage that can be used, or 0 if there is no limit.
+		// DisabledFeatures are features of the interface disabled through the web config
+		// of the domain of the account, see config.WebFeatures.
 		async Account() {
 			const fn = "Account";
 			const paramTypes = [];
-			const returnTypes = [["Account"], ["int64"], ["int64"], ["[]", "Suppression"]];
+			const returnTypes = [["Account"], ["int64"], ["int64"], ["[]", "Suppression"], ["[]", "string"]];
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
@@ -443,6 +670,49 @@ var api;
 			const params = [destName, oldDest, newDest];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// AliasMemberSave saves the delivery preferences of an address of the account
+		// that is a member of the alias.
+		async AliasMemberSave(aliasAddress, memberAddress, member) {
+			const fn = "AliasMemberSave";
+			const paramTypes = [["string"], ["string"], ["AliasMember"]];
+			const returnTypes = [];
+			const params = [aliasAddress, memberAddress, member];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AliasLeave removes an address of the account from the members of the alias.
+		async AliasLeave(aliasAddress, memberAddress) {
+			const fn = "AliasLeave";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [];
+			const params = [aliasAddress, memberAddress];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AliasesOwned returns the aliases that have an address of the account as owner.
+		async AliasesOwned() {
+			const fn = "AliasesOwned";
+			const paramTypes = [];
+			const returnTypes = [["[]", "OwnedAlias"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AliasOwnerMembersAdd adds member addresses to an alias owned by the account.
+		// Members must be addresses of local accounts.
+		async AliasOwnerMembersAdd(aliasAddress, addresses) {
+			const fn = "AliasOwnerMembersAdd";
+			const paramTypes = [["string"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [aliasAddress, addresses];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AliasOwnerMembersRemove removes member addresses from an alias owned by the
+		// account.
+		async AliasOwnerMembersRemove(aliasAddress, addresses) {
+			const fn = "AliasOwnerMembersRemove";
+			const paramTypes = [["string"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [aliasAddress, addresses];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ImportAbort aborts an import that is in progress. If the import exists and isn't
 		// finished, no changes will have been made by the import.
 		async ImportAbort(importToken) {
@@ -568,6 +838,101 @@ var api;
 			const params = [mailbox, keep];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ScreeningSave saves the screening settings for first-time senders. A nil value
+		// disables screening.
+		async ScreeningSave(screening) {
+			const fn = "ScreeningSave";
+			const paramTypes = [["nullable", "Screening"]];
+			const returnTypes = [];
+			const params = [screening];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// CategoriesSave saves the settings for categorizing incoming bulk messages into
+		// newsletters, notifications and social updates. A nil value disables
+		// categorization, a nil category disables that category.
+		async CategoriesSave(categories) {
+			const fn = "CategoriesSave";
+			const paramTypes = [["nullable", "Categories"]];
+			const returnTypes = [];
+			const params = [categories];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderList returns the entries of the sender allow and block lists.
+		async SenderList() {
+			const fn = "SenderList";
+			const paramTypes = [];
+			const returnTypes = [["[]", "SenderListEntry"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderListAdd adds an address ("user@example.org"), domain ("example.org") or
+		// wildcard domain ("*.example.org", also matching example.org) to the sender
+		// lists, with action "allow" (accept without junk filtering if the message From
+		// address is verified), "reject" (reject during SMTP) or "discard" (accept
+		// during SMTP, but don't deliver). For a pattern already present, the action is
+		// changed.
+		async SenderListAdd(pattern, action) {
+			const fn = "SenderListAdd";
+			const paramTypes = [["string"], ["SenderListAction"]];
+			const returnTypes = [["SenderListEntry"]];
+			const params = [pattern, action];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderListRemove removes an entry from the sender lists.
+		async SenderListRemove(id) {
+			const fn = "SenderListRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [id];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// IdentitiesSave saves the identities for sending messages with webmail. Each
+		// identity address must be allowed as message From address for the account.
+		async IdentitiesSave(identities) {
+			const fn = "IdentitiesSave";
+			const paramTypes = [["[]", "Identity"]];
+			const returnTypes = [];
+			const params = [identities];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PGPKeys returns the OpenPGP keys of the account.
+		async PGPKeys() {
+			const fn = "PGPKeys";
+			const paramTypes = [];
+			const returnTypes = [["[]", "PGPKey"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PGPKeyAdd adds an armored OpenPGP key to the account. A public key is used for
+		// encrypting messages to, and verifying messages from, the addresses of the key.
+		// A private key, typically encrypted with a passphrase, is used for signing and
+		// decrypting messages. A private key can be added for a public key that is
+		// already present. Keys with EdDSA or Curve25519 algorithms are not supported.
+		async PGPKeyAdd(armored) {
+			const fn = "PGPKeyAdd";
+			const paramTypes = [["string"]];
+			const returnTypes = [["PGPKey"]];
+			const params = [armored];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PGPKeyPublish changes whether a key is served in the OpenPGP Web Key Directory,
+		// for the addresses of the key that belong to the account. Only keys with a
+		// private key can be published.
+		async PGPKeyPublish(fingerprint, publish) {
+			const fn = "PGPKeyPublish";
+			const paramTypes = [["string"], ["bool"]];
+			const returnTypes = [];
+			const params = [fingerprint, publish];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PGPKeyRemove removes a key from the account.
+		async PGPKeyRemove(fingerprint) {
+			const fn = "PGPKeyRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [fingerprint];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		async TLSPublicKeys() {
 			const fn = "TLSPublicKeys";
 			const paramTypes = [];
@@ -603,6 +968,34 @@ var api;
 			const params = [limit];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Sessions returns the active web sessions and IMAP/submission connections of the
+		// account.
+		async Sessions() {
+			const fn = "Sessions";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Session"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SessionRevoke ends a session. A web session is logged out, a connection is
+		// closed. Clients with a stored password can log in again, change the password to
+		// prevent that.
+		async SessionRevoke(web, id) {
+			const fn = "SessionRevoke";
+			const paramTypes = [["bool"], ["int64"]];
+			const returnTypes = [];
+			const params = [web, id];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SessionsRevokeAll ends all sessions except the session of this request, and
+		// returns the number of sessions ended.
+		async SessionsRevokeAll() {
+			const fn = "SessionsRevokeAll";
+			const paramTypes = [];
+			const returnTypes = [["int32"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 	}
 	api.Client = Client;
 	api.defaultBaseURL = (function () {
@@ -955,6 +1348,8 @@ var api;
 	};
 })(api || (api = {}));
 // Javascript is generated from typescript, do not modify generated javascript because changes will be overwritten.
+// Per-domain customization, from branding.js.
+const branding = window.moxBranding || {};
 let moxversion;
 let moxgoos;
 let moxgoarch;
@@ -966,6 +1361,28 @@ const login = async (reason) => {
 		let autosize;
 		let username;
 		let password;
+		let totpLabel;
+		let totp;
+		let sso;
+		const loggedIn = (token, address) => {
+			try {
+				if (address) {
+					window.localStorage.setItem('webaccountaddress', address);
+				}
+				else {
+					window.localStorage.removeItem('webaccountaddress');
+				}
+				window.localStorage.setItem('webaccountcsrftoken', token);
+			}
+			catch (err) {
+				console.log('saving csrf token in localStorage', err);
+			}
+			root.remove();
+			if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
+				origFocus.focus();
+			}
+			resolve(token);
+		};
 		const root = dom.div(style({ position: 'absolute', top: 0, right: 0, bottom: 0, left: 0, backgroundColor: '#eee', display: 'flex', alignItems: 'center', justifyContent: 'center', zIndex: '1', animation: 'fadein .15s ease-in' }), dom.div(style({ display: 'flex', flexDirection: 'column', alignItems: 'center' }), reasonElem = reason ? dom.div(style({ marginBottom: '2ex', textAlign: 'center' }), reason) : dom.div(), dom.div(style({ backgroundColor: 'white', borderRadius: '.25em', padding: '1em', boxShadow: '0 0 20px rgba(0, 0, 0, 0.1)', border: '1px solid #ddd', maxWidth: '95vw', overflowX: 'auto', maxHeight: '95vh', overflowY: 'auto', marginBottom: '20vh' }), dom.form(async function submit(e) {
 			e.preventDefault();
 			e.stopPropagation();
@@ -973,32 +1390,106 @@ const login = async (reason) => {
 			try {
 				fieldset.disabled = true;
 				const loginToken = await client.LoginPrep();
-				const token = await client.Login(loginToken, username.value, password.value);
-				try {
-					window.localStorage.setItem('webaccountaddress', username.value);
-					window.localStorage.setItem('webaccountcsrftoken', token);
-				}
-				catch (err) {
-					console.log('saving csrf token in localStorage', err);
-				}
-				root.remove();
-				if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
-					origFocus.focus();
-				}
-				resolve(token);
+				const token = await client.Login(loginToken, username.value, password.value, totp.value);
+				loggedIn(token, username.value);
 			}
 			catch (err) {
 				console.log('login error', err);
+				if (err.code === 'user:totpRequired') {
+					// Password is valid, ask for the code from the authenticator app.
+					totpLabel.style.display = 'block';
+					totp.required = true;
+					fieldset.disabled = false;
+					totp.focus();
+					return;
+				}
 				window.alert('Error: ' + errmsg(err));
 			}
 			finally {
 				fieldset.disabled = false;
 			}
-		}, fieldset = dom.fieldset(dom.h1('Account'), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Email address', style({ marginBottom: '.5ex' })), autosize = dom.span(dom._class('autosize'), username = dom.input(attr.required(''), attr.autocomplete('username'), attr.placeholder('jane@example.org'), function change() { autosize.dataset.value = username.value; }, function input() { autosize.dataset.value = username.value; }))), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Password', style({ marginBottom: '.5ex' })), password = dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required(''))), dom.div(style({ textAlign: 'center' }), dom.submitbutton('Login')))))));
+		}, fieldset = dom.fieldset(dom.h1(branding.Name || 'Account'), branding.Logo ? dom.div(style({ textAlign: 'center', marginBottom: '2ex' }), dom.img(attr.src('branding/logo'), style({ maxWidth: '20em', maxHeight: '6em' }))) : [], branding.LoginText ? dom.div(style({ marginBottom: '2ex', maxWidth: '30em', whiteSpace: 'pre-wrap' }), branding.LoginText) : [], dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Email address', style({ marginBottom: '.5ex' })), autosize = dom.span(dom._class('autosize'), username = dom.input(attr.required(''), attr.autocomplete('username'), attr.placeholder('jane@example.org'), function change() { autosize.dataset.value = username.value; }, function input() { autosize.dataset.value = username.value; }))), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Password', style({ marginBottom: '.5ex' })), password = dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required(''))), totpLabel = dom.label(style({ display: 'none', marginBottom: '2ex' }), dom.div('Two-factor authentication code, or recovery code', style({ marginBottom: '.5ex' })), totp = dom.input(attr.autocomplete('one-time-code'))), dom.div(style({ textAlign: 'center' }), dom.submitbutton('Login'), window.PublicKeyCredential ? [
+			' ',
+			dom.clickbutton('Login with passkey', attr.title('Login with a passkey registered for your account, without password. Or, after entering your email address and password, use the passkey as second factor.'), async function click() {
+				reasonElem.remove();
+				try {
+					fieldset.disabled = true;
+					const loginToken = await client.LoginPrep();
+					const opts = await client.PasskeyLoginStart(loginToken);
+					const assertion = await passkeyGet(opts);
+					const address = password.value ? username.value : '';
+					const token = await client.PasskeyLogin(loginToken, address, password.value, assertion);
+					loggedIn(token, address);
+				}
+				catch (err) {
+					console.log('passkey login error', err);
+					window.alert('Error: ' + errmsg(err));
+				}
+				finally {
+					fieldset.disabled = false;
+				}
+			}),
+		] : [], sso = dom.span()))))));
 		document.body.appendChild(root);
 		username.focus();
+		// Offer login through the identity provider, if configured.
+		client.OIDCEnabled().then(enabled => {
+			if (enabled) {
+				dom._kids(sso, ' ', dom.clickbutton('Login with single sign-on', attr.title('Login through the identity provider of your organization.'), function click() {
+					window.location.href = 'oidc/login';
+				}));
+			}
+		}, err => console.log('checking single sign-on', err));
 	});
 };
+// The WebAuthn browser API uses ArrayBuffers, the API base64 strings.
+const base64ToBuffer = (s) => Uint8Array.from(window.atob(s || ''), c => c.charCodeAt(0)).buffer;
+const bufferToBase64 = (buf) => window.btoa(String.fromCharCode(...new Uint8Array(buf)));
+// passkeyGet lets the user select a passkey in the browser, returning the signed
+// response for the login.
+const passkeyGet = async (opts) => {
+	const cred = await window.navigator.credentials.get({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rpId: opts.RPID,
+			timeout: opts.Timeout,
+			userVerification: 'preferred',
+		},
+	});
+	if (!cred) {
+		throw new Error('no passkey selected');
+	}
+	const resp = cred.response;
+	return {
+		CredentialID: bufferToBase64(cred.rawId),
+		ClientDataJSON: bufferToBase64(resp.clientDataJSON),
+		AuthenticatorData: bufferToBase64(resp.authenticatorData),
+		Signature: bufferToBase64(resp.signature),
+	};
+};
+// passkeyCreate lets the user create a new passkey in the browser or on a
+// security key, returning the client data and attestation object for the
+// registration.
+const passkeyCreate = async (opts) => {
+	const cred = await window.navigator.credentials.create({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rp: { id: opts.RPID, name: opts.RPName },
+			user: { id: base64ToBuffer(opts.UserID), name: opts.UserName, displayName: opts.UserName },
+			pubKeyCredParams: (opts.Algorithms || []).map(alg => ({ type: 'public-key', alg: alg })),
+			excludeCredentials: (opts.ExcludeCredentials || []).map(id => ({ type: 'public-key', id: base64ToBuffer(id) })),
+			// Passkeys must be discoverable, logins don't specify credentials.
+			authenticatorSelection: { residentKey: 'required', userVerification: 'preferred' },
+			attestation: 'none',
+			timeout: opts.Timeout,
+		},
+	});
+	if (!cred) {
+		throw new Error('no passkey created');
+	}
+	const resp = cred.response;
+	return [bufferToBase64(resp.clientDataJSON), bufferToBase64(resp.attestationObject)];
+};
 // Popup shows kids in a centered div with white background on top of a
 // transparent overlay on top of the window. Clicking the overlay or hitting
 // Escape closes the popup. Scrollbars are automatically added to the div with
@@ -1173,12 +1664,23 @@ const formatQuotaSize = (v) => {
 	return '' + v;
 };
 const index = async () => {
-	const [[acc, storageUsed, storageLimit, suppressions], tlspubkeys0, recentLoginAttempts] = await Promise.all([
+	const [[acc, storageUsed, storageLimit, suppressions, disabledFeatures], tlspubkeys0, recentLoginAttempts, pgpkeys0, sessions, passwordStatus, twoFactorStatus, passkeys, senderList0] = await Promise.all([
 		client.Account(),
 		client.TLSPublicKeys(),
 		client.LoginAttempts(10),
+		client.PGPKeys(),
+		client.Sessions(),
+		client.PasswordStatus(),
+		client.TwoFactorStatus(),
+		client.Passkeys(),
+		client.SenderList(),
 	]);
 	const tlspubkeys = tlspubkeys0 || [];
+	const pgpkeys = pgpkeys0 || [];
+	const senderList = senderList0 || [];
+	const ownedAliases = (acc.OwnedAliases || []).length > 0 ? await client.AliasesOwned() : [];
+	// Sections for features disabled for the domain are kept, but hidden.
+	const featureHidden = (feature) => (disabledFeatures || []).includes(feature) ? style({ display: 'none' }) : [];
 	let fullNameForm;
 	let fullNameFieldset;
 	let fullName;
@@ -1187,6 +1689,7 @@ const index = async () => {
 	let password1;
 	let password2;
 	let passwordHint;
+	let recoveryCodesBox;
 	let autoJunkFlagsFieldset;
 	let autoJunkFlagsEnabled;
 	let junkMailboxRegexp;
@@ -1195,6 +1698,9 @@ const index = async () => {
 	let junkFilterFields;
 	let junkFilterEnabled;
 	let junkThreshold;
+	let junkTagThreshold;
+	let junkJunkThreshold;
+	let junkHighAction;
 	let junkOnegrams;
 	let junkTwograms;
 	let junkMaxPower;
@@ -1204,6 +1710,30 @@ const index = async () => {
 	let rejectsFieldset;
 	let rejectsMailbox;
 	let keepRejects;
+	let screeningFieldset;
+	let screeningEnabled;
+	let screeningMailbox;
+	let categoriesFieldset;
+	const categoryInputs = [];
+	const categoryRow = (name, title, cat) => {
+		const enabled = dom.input(attr.type('checkbox'), cat ? attr.checked('') : []);
+		const mailbox = dom.input(attr.value(cat?.Mailbox || ''), attr.placeholder('Keyword only'));
+		const domains = dom.input(attr.value((cat?.Domains || []).join(', ')), attr.placeholder('example.org, ...'));
+		categoryInputs.push({ enabled: enabled, mailbox: mailbox, domains: domains });
+		return dom.tr(dom.td(dom.label(enabled, ' ', name, attr.title(title))), dom.td(mailbox), dom.td(domains));
+	};
+	const categoryValue = (i) => {
+		const x = categoryInputs[i];
+		if (!x.enabled.checked) {
+			return null;
+		}
+		return {
+			Mailbox: x.mailbox.value.trim(),
+			Domains: x.domains.value.split(',').map(s => s.trim()).filter(s => !!s),
+		};
+	};
+	let senderListPattern;
+	let senderListAction;
 	let outgoingWebhookFieldset;
 	let outgoingWebhookURL;
 	let outgoingWebhookAuthorization;
@@ -1215,6 +1745,7 @@ const index = async () => {
 	let keepRetiredMessagePeriod;
 	let keepRetiredWebhookPeriod;
 	let fromIDLoginAddressesFieldset;
+	let identitiesFieldset;
 	const second = 1000 * 1000 * 1000;
 	const minute = 60 * second;
 	const hour = 60 * minute;
@@ -1387,6 +1918,16 @@ const index = async () => {
 			SMTPEnhancedCode: '',
 			Error: '',
 			Extra: {},
+			Recipient: '',
+			Attempts: 0,
+			MaxAttempts: 0,
+			NextAttempt: null,
+			RemoteMTA: '',
+			SMTPResponse: [],
+			TLS: '',
+			MTASTS: false,
+			DANE: false,
+			Transcript: '',
 		};
 		const onchange = function change() {
 			data = {
@@ -1403,6 +1944,16 @@ const index = async () => {
 				SMTPEnhancedCode: '',
 				Error: error.value,
 				Extra: JSON.parse(extra.value),
+				Recipient: '',
+				Attempts: 0,
+				MaxAttempts: 0,
+				NextAttempt: null,
+				RemoteMTA: '',
+				SMTPResponse: [],
+				TLS: '',
+				MTASTS: false,
+				DANE: false,
+				Transcript: '',
 			};
 			const curlStr = "curl " + (outgoingWebhookAuthorization.value ? "-H 'Authorization: " + outgoingWebhookAuthorization.value + "' " : "") + "-H 'X-Mox-Webhook-ID: 1' -H 'X-Mox-Webhook-Attempt: 1' --json '" + JSON.stringify(data) + "' '" + outgoingWebhookURL.value + "'";
 			dom._kids(curl, style({ maxWidth: '45em', wordBreak: 'break-all' }), curlStr);
@@ -1484,15 +2035,101 @@ const index = async () => {
 		body.setAttribute('rows', '' + Math.min(40, (body.value.split('\n').length + 1)));
 		onchange();
 	};
-	const root = dom.div(crumbs('Mox Account'), dom.div('Default domain: ', acc.DNSDomain.ASCII ? domainString(acc.DNSDomain) : '(none)'), dom.br(), fullNameForm = dom.form(fullNameFieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Full name', dom.br(), fullName = dom.input(attr.value(acc.FullName), attr.title('Name to use in From header when composing messages. Can be overridden per configured address.'))), ' ', dom.submitbutton('Save')), async function submit(e) {
+	const root = dom.div(crumbs(branding.Name || 'Mox Account'), dom.div('Default domain: ', acc.DNSDomain.ASCII ? domainString(acc.DNSDomain) : '(none)'), dom.br(), fullNameForm = dom.form(fullNameFieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Full name', dom.br(), fullName = dom.input(attr.value(acc.FullName), attr.title('Name to use in From header when composing messages. Can be overridden per configured address.'))), ' ', dom.submitbutton('Save')), async function submit(e) {
 		e.preventDefault();
 		await check(fullNameFieldset, client.AccountSaveFullName(fullName.value));
 		fullName.setAttribute('value', fullName.value);
 		fullNameForm.reset();
-	}), dom.br(), dom.h2('Addresses'), dom.ul(Object.entries(acc.Destinations || {}).length === 0 ? dom.li('(None, login disabled)') : [], Object.entries(acc.Destinations || {}).sort().map(t => dom.li(dom.a(prewrap(t[0]), attr.href('#destinations/' + encodeURIComponent(t[0]))), t[0].startsWith('@') ? ' (catchall)' : []))), dom.br(), dom.h2('Aliases/lists'), dom.table(dom.thead(dom.tr(dom.th('Alias address', attr.title('Messages sent to this address will be delivered to all members of the alias/list. A member does not receive a message if their address is in the message From header.')), dom.th('Subscription address', attr.title('Address subscribed to the alias/list.')), dom.th('Allowed senders', attr.title('Whether only members can send through the alias/list, or anyone.')), dom.th('Send as alias address', attr.title('If enabled, messages can be sent with the alias address in the message "From" header.')), dom.th())), (acc.Aliases || []).length === 0 ? dom.tr(dom.td(attr.colspan('5'), 'None')) : [], (acc.Aliases || []).sort((a, b) => a.Alias.LocalpartStr < b.Alias.LocalpartStr ? -1 : (domainName(a.Alias.Domain) < domainName(b.Alias.Domain) ? -1 : 1)).map(a => dom.tr(dom.td(prewrap(a.Alias.LocalpartStr, '@', domainName(a.Alias.Domain))), dom.td(prewrap(a.SubscriptionAddress)), dom.td(a.Alias.PostPublic ? 'Anyone' : 'Members only'), dom.td(a.Alias.AllowMsgFrom ? 'Yes' : 'No'), dom.td((a.MemberAddresses || []).length === 0 ? [] :
-		dom.clickbutton('Show members', function click() {
-			popup(dom.h1('Members of alias ', prewrap(a.Alias.LocalpartStr, '@', domainName(a.Alias.Domain))), dom.ul((a.MemberAddresses || []).map(addr => dom.li(prewrap(addr)))));
-		}))))), dom.br(), dom.h2('Recent login attempts', attr.title('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored to prevent unlimited growth of the database.')), renderLoginAttempts(recentLoginAttempts || []), dom.br(), recentLoginAttempts && recentLoginAttempts.length >= 10 ? dom.p('See ', dom.a(attr.href('#loginattempts'), 'all login attempts'), '.') : dom.br(), dom.h2('Change password'), acc.NoCustomPassword ?
+	}), dom.br(), dom.h2('Addresses'), dom.ul(Object.entries(acc.Destinations || {}).length === 0 ? dom.li('(None, login disabled)') : [], Object.entries(acc.Destinations || {}).sort().map(t => dom.li(dom.a(prewrap(t[0]), attr.href('#destinations/' + encodeURIComponent(t[0]))), t[0].startsWith('@') ? ' (catchall)' : []))), dom.br(), dom.h2('Aliases/lists'), dom.table(dom.thead(dom.tr(dom.th('Alias address', attr.title('Messages sent to this address will be delivered to all members of the alias/list. A member does not receive a message if their address is in the message From header.')), dom.th('Subscription address', attr.title('Address subscribed to the alias/list.')), dom.th('Allowed senders', attr.title('Whether only members can send through the alias/list, or anyone.')), dom.th('Send as alias address', attr.title('If enabled, messages can be sent with the alias address in the message "From" header.')), dom.th('Delivery', attr.title('Whether and where messages from the alias/list are delivered for the subscription address.')), dom.th())), (acc.Aliases || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [], (acc.Aliases || []).sort((a, b) => a.Alias.LocalpartStr < b.Alias.LocalpartStr ? -1 : (domainName(a.Alias.Domain) < domainName(b.Alias.Domain) ? -1 : 1)).map(a => {
+		const aliasAddress = a.Alias.LocalpartStr + '@' + domainName(a.Alias.Domain);
+		const policy = a.Alias.PostPolicy || (a.Alias.PostPublic ? 'anyone' : 'members');
+		return dom.tr(dom.td(prewrap(aliasAddress)), dom.td(prewrap(a.SubscriptionAddress)), dom.td(policy === 'anyone' ? 'Anyone' : (policy === 'moderated' ? 'Moderated' : 'Members only')), dom.td(a.Alias.AllowMsgFrom ? 'Yes' : 'No'), dom.td(a.Member.NoDelivery ? 'None' : (a.Member.Mailbox ? 'Mailbox ' + a.Member.Mailbox : 'Default'), ' ', dom.clickbutton('Change', function click() {
+			let fieldset;
+			let noDelivery;
+			let mailbox;
+			popup(dom.h1('Delivery for alias ', prewrap(aliasAddress)), dom.form(async function submit(e) {
+				e.preventDefault();
+				e.stopPropagation();
+				await check(fieldset, client.AliasMemberSave(aliasAddress, a.SubscriptionAddress, { NoDelivery: noDelivery.checked, Mailbox: mailbox.value }));
+				window.location.reload(); // todo: reload less
+			}, fieldset = dom.fieldset(dom.label(style({ display: 'block', marginBottom: '1ex' }), noDelivery = dom.input(attr.type('checkbox'), a.Member.NoDelivery ? attr.checked('') : []), ' No delivery', attr.title('Messages sent to the alias/list are not delivered to this address. You can still send messages to the alias/list.')), dom.label(style({ display: 'block', marginBottom: '1ex' }), dom.div('Mailbox', attr.title('Mailbox to deliver messages from the alias/list to. If empty, the mailbox configured for the destination is used.')), mailbox = dom.input(attr.value(a.Member.Mailbox))), dom.submitbutton('Save'))));
+		})), dom.td((a.MemberAddresses || []).length === 0 ? [] : [
+			dom.clickbutton('Show members', function click() {
+				popup(dom.h1('Members of alias ', prewrap(aliasAddress)), dom.ul((a.MemberAddresses || []).map(addr => dom.li(prewrap(addr)))));
+			}),
+			' ',
+		], dom.clickbutton('Leave', attr.title('Remove the subscription address from the members of the alias/list.'), async function click(e) {
+			if (!window.confirm('Are you sure you want to leave this alias/list?')) {
+				return;
+			}
+			await check(e.target, client.AliasLeave(aliasAddress, a.SubscriptionAddress));
+			window.location.reload(); // todo: reload less
+		})));
+	})), dom.br(), ownedAliases.length === 0 ? [] : [
+		dom.h2('Owned aliases/lists', attr.title('Aliases/lists with an address of this account as owner. Owners manage the members. For moderated lists, messages from others than the owners are delivered to the owners only, who can resend approved messages to the list.')),
+		dom.table(dom.thead(dom.tr(dom.th('Alias address'), dom.th('Allowed senders'), dom.th('Members'), dom.th())), ownedAliases.map(o => {
+			let fieldset;
+			let addresses;
+			return dom.tr(dom.td(prewrap(o.Address)), dom.td(o.PostPolicy === 'anyone' ? 'Anyone' : (o.PostPolicy === 'moderated' ? 'Moderated' : 'Members only')), dom.td((o.Members || []).map(m => dom.div(prewrap(m), ' ', dom.clickbutton('Remove', async function click(e) {
+				await check(e.target, client.AliasOwnerMembersRemove(o.Address, [m]));
+				window.location.reload(); // todo: reload less
+			})))), dom.td(dom.form(async function submit(e) {
+				e.preventDefault();
+				e.stopPropagation();
+				await check(fieldset, client.AliasOwnerMembersAdd(o.Address, addresses.value.split('\n').map(s => s.trim()).filter(s => s)));
+				window.location.reload(); // todo: reload less
+			}, fieldset = dom.fieldset(addresses = dom.textarea(attr.required(''), attr.rows('1'), attr.placeholder('localpart@domain'), attr.title('Addresses of local accounts, one per line.'), function focus() { addresses.setAttribute('rows', '5'); }), ' ', dom.submitbutton('Add members', style({ verticalAlign: 'top' }))))));
+		})),
+		dom.br(),
+	], dom.br(), dom.h2('Identities', attr.title('Identities for sending messages with webmail, each with a From address, display name and signature. Addresses must be allowed as message From address for the account, e.g. one of the addresses above or an alias that allows sending as the alias address. If no identity is configured, the account addresses are used with the signature from the webmail settings. An HTML signature causes messages sent with the identity to get an HTML version in addition to the plain text.')), (() => {
+		let rows = [];
+		let elem;
+		const render = () => {
+			rows = [];
+			const e = dom.form(async function submit(e) {
+				e.preventDefault();
+				e.stopPropagation();
+				const l = rows.map(fn => fn());
+				await check(identitiesFieldset, client.IdentitiesSave(l));
+				acc.Identities = l;
+			}, identitiesFieldset = dom.fieldset(dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Display name'), dom.th('Signature'), dom.th('HTML signature', attr.title('Optional. If set, messages get an HTML version, with this HTML in place of the text signature.')), dom.th('Reply quoting'), dom.th('Reply-To', attr.title('Optional default Reply-To address for messages composed with this identity.')), dom.th('Default', attr.title('Selected by default when composing new messages.')), dom.th())), dom.tbody((acc.Identities || []).length === 0 ? dom.tr(dom.td(attr.colspan('8'), '(None)')) : [], (acc.Identities || []).map((ident, index) => {
+				let address;
+				let displayName;
+				let signature;
+				let signatureHTML;
+				let replyQuoting;
+				let replyTo;
+				let isDefault;
+				const x = dom.tr(dom.td(address = dom.input(attr.required(''), attr.value(ident.Address))), dom.td(displayName = dom.input(attr.value(ident.DisplayName))), dom.td(signature = dom.textarea(new String((ident.Signature || []).join('\n')), attr.rows('3'))), dom.td(signatureHTML = dom.input(attr.value(ident.SignatureHTML))), dom.td(replyQuoting = dom.select(dom.option(attr.value(''), 'Default'), dom.option(attr.value('bottom'), 'Bottom', ident.ReplyQuoting === 'bottom' ? attr.selected('') : []), dom.option(attr.value('top'), 'Top', ident.ReplyQuoting === 'top' ? attr.selected('') : []))), dom.td(replyTo = dom.input(attr.value(ident.ReplyTo))), dom.td(isDefault = dom.input(attr.type('checkbox'), ident.Default ? attr.checked('') : [])), dom.td(dom.clickbutton('Remove', function click() {
+					acc.Identities.splice(index, 1);
+					render();
+				})));
+				rows.push(() => {
+					return {
+						Address: address.value,
+						DisplayName: displayName.value,
+						Signature: signature.value ? signature.value.split('\n') : [],
+						SignatureHTML: signatureHTML.value,
+						ReplyQuoting: replyQuoting.value,
+						ReplyTo: replyTo.value,
+						Default: isDefault.checked,
+					};
+				});
+				return x;
+			})), dom.tfoot(dom.tr(dom.td(attr.colspan('7')), dom.td(dom.clickbutton('Add', function click() {
+				const addr = Object.keys(acc.Destinations || {}).filter(s => !s.startsWith('@'))[0] || '';
+				acc.Identities = (acc.Identities || []).concat([{ Address: addr, DisplayName: acc.FullName, Signature: [], SignatureHTML: '', ReplyQuoting: '', ReplyTo: '', Default: false }]);
+				render();
+			}))), dom.tr(dom.td(attr.colspan('8'), dom.submitbutton('Save')))))));
+			if (elem) {
+				elem.replaceWith(e);
+				elem = e;
+			}
+			return e;
+		};
+		elem = render();
+		return elem;
+	})(), dom.br(), dom.h2('Sessions', attr.title('Logins to the webmail and account web interfaces, and authenticated IMAP and submission connections. If you suspect someone else has access to your account, revoke sessions and change your password.')), renderSessions(sessions || []), dom.br(), dom.h2('Recent login attempts', attr.title('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored to prevent unlimited growth of the database.')), renderLoginAttempts(recentLoginAttempts || []), dom.br(), recentLoginAttempts && recentLoginAttempts.length >= 10 ? dom.p('See ', dom.a(attr.href('#loginattempts'), 'all login attempts'), '.') : dom.br(), dom.h2('Change password'), passwordStatus.Expired ? box(red, 'Your password has expired. Set a new password to login with other protocols than this account web interface again, e.g. IMAP and SMTP submission.') : [], dom.p('Password last changed: ', passwordStatus.Changed.getTime() > 0 ? [age(passwordStatus.Changed), ' ago'] : 'unknown', passwordStatus.Expires.getTime() > 0 ? ['. Expires: ', passwordStatus.Expires.toLocaleString()] : [], '.'), acc.NoCustomPassword ?
 		dom.div(dom.clickbutton('Generate and set new password', attr.title('Automatically generate a new password and set it for this account. Custom passwords risk reuse across services and are currently disabled for this account.'), async function click(e) {
 			const password = await check(e.target, client.GeneratePassword());
 			window.alert('New password: ' + password + '\n\nStore it securely, for example in a password manager.');
@@ -1524,7 +2161,13 @@ const index = async () => {
 			}
 			await check(passwordFieldset, client.SetPassword(password1.value));
 			passwordForm.reset();
-		}), dom.br(), dom.h2('TLS public keys'), dom.p('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.'), (() => {
+		}), dom.br(), dom.h2('Recovery codes', attr.title('A recovery code can be used once instead of a two-factor authentication code when logging in to the web interfaces, e.g. if you lost your authenticator app. Your password is still required.')), recoveryCodesBox = dom.div(dom.p('Unused recovery codes: ' + passwordStatus.RecoveryCodes)), dom.clickbutton('Generate new recovery codes', attr.title('Generate new recovery codes, replacing any existing codes.'), async function click(e) {
+		if (passwordStatus.RecoveryCodes > 0 && !window.confirm('Are you sure? Existing recovery codes will no longer work.')) {
+			return;
+		}
+		const codes = await check(e.target, client.RecoveryCodesGenerate());
+		dom._kids(recoveryCodesBox, dom.p('New recovery codes, each can be used once. Store them securely, they cannot be shown again:'), dom.pre(dom._class('literal'), (codes || []).join('\n')));
+	}), dom.br(), dom.h2('Two-factor authentication', attr.title('With two-factor authentication, logins to the web interfaces require a code from an authenticator app on your phone in addition to your password. IMAP/SMTP clients and other protocols cannot use your account password anymore, they must use app passwords.')), renderTwoFactor(twoFactorStatus), dom.br(), dom.h2('Passkeys', attr.title('A passkey, stored in your browser, password manager, phone or security key, can be used to login to the web interfaces without password. Passkeys can also be used as second factor after the password, e.g. when two-factor authentication is required.')), renderPasskeys(passkeys || []), dom.br(), dom.div(featureHidden('tlspubkeys'), dom.h2('TLS public keys'), dom.p('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.'), (() => {
 		let elem = dom.div();
 		const preauthHelp = 'New IMAP immediate TLS connections authenticated with a client certificate are automatically switched to "authenticated" state with an untagged IMAP "preauth" message by default. IMAP connections have a state machine specifying when commands are allowed. Authenticating is not allowed while in the "authenticated" state. Enable this option to work around clients that would try to authenticated anyway.';
 		const render = () => {
@@ -1621,7 +2264,46 @@ openssl pkcs12 \\
 		};
 		render();
 		return elem;
-	})(), dom.br(), dom.h2('Disk usage'), dom.p('Storage used is ', dom.b(formatQuotaSize(Math.floor(storageUsed / (1024 * 1024)) * 1024 * 1024)), storageLimit > 0 ? [
+	})(), dom.br()), dom.div(featureHidden('pgp'), dom.h2('OpenPGP keys'), dom.p('Keys for signing, encrypting, verifying and decrypting messages in the webmail. Add public keys of correspondents to verify their signatures and encrypt messages to them. Add your own key including private key to sign messages and decrypt messages sent to you. Keys with a private key can be published in the Web Key Directory of your domain, where other mail clients look up keys for encrypting messages to you.'), (() => {
+		let elem = dom.div();
+		const render = () => {
+			const e = dom.div(dom.table(dom.thead(dom.tr(dom.th('Key ID'), dom.th('Addresses'), dom.th('Created'), dom.th('Private key'), dom.th('Publish', attr.title('Publish the public key in the Web Key Directory of the domain of the address.')), dom.th('Remove'))), dom.tbody(pgpkeys.length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [], pgpkeys.map(k => dom.tr(dom.td(k.KeyID, attr.title('Fingerprint: ' + k.Fingerprint)), dom.td((k.Addresses || []).join(', ')), dom.td(k.Created.toISOString().split('T')[0]), dom.td(k.Private ? 'Yes' : 'No'), dom.td(k.Private ?
+				dom.input(attr.type('checkbox'), k.Publish ? attr.checked('') : [], async function change(e) {
+					const elem = e.target;
+					await check(elem, client.PGPKeyPublish(k.Fingerprint, elem.checked));
+					k.Publish = elem.checked;
+				}) : '-'), dom.td(dom.clickbutton('Remove', async function click(e) {
+				if (!window.confirm('Are you sure you want to remove this key?')) {
+					return;
+				}
+				await check(e.target, client.PGPKeyRemove(k.Fingerprint));
+				pgpkeys.splice(pgpkeys.indexOf(k), 1);
+				render();
+			})))))), dom.clickbutton('Add', style({ marginTop: '1ex' }), function click() {
+				let armored;
+				const close = popup(dom.div(style({ maxWidth: '45em' }), dom.h1('Add OpenPGP key'), dom.form(async function submit(e) {
+					e.preventDefault();
+					e.stopPropagation();
+					const nk = await check(e.target, client.PGPKeyAdd(armored.value));
+					const i = pgpkeys.findIndex(k => k.Fingerprint === nk.Fingerprint);
+					if (i >= 0) {
+						pgpkeys[i] = nk;
+					}
+					else {
+						pgpkeys.push(nk);
+					}
+					render();
+					close();
+				}, dom.label(style({ display: 'block', marginBottom: '1ex' }), dom.div(dom.b('Armored key')), armored = dom.textarea(attr.required(''), attr.rows('12'), style({ width: '100%', fontFamily: 'monospace' })), dom.div(style({ fontStyle: 'italic', marginTop: '.5ex' }), 'A public key, or a private key, e.g. exported with "gpg --armor --export-secret-keys". Private keys are stored as given, typically protected with a passphrase, which is needed when signing or decrypting. Adding the private key for an existing public key adds it to the key.')), dom.submitbutton('Add'))));
+			}));
+			if (elem) {
+				elem.replaceWith(e);
+			}
+			elem = e;
+		};
+		render();
+		return elem;
+	})(), dom.br()), dom.h2('Disk usage'), dom.p('Storage used is ', dom.b(formatQuotaSize(Math.floor(storageUsed / (1024 * 1024)) * 1024 * 1024)), storageLimit > 0 ? [
 		dom.b('/', formatQuotaSize(storageLimit)),
 		' (',
 		'' + Math.floor(100 * storageUsed / storageLimit),
@@ -1639,6 +2321,9 @@ openssl pkcs12 \\
 			}
 			const r = {
 				Threshold: parseFloat(junkThreshold.value),
+				TagThreshold: parseFloat(junkTagThreshold.value) || 0,
+				JunkThreshold: parseFloat(junkJunkThreshold.value) || 0,
+				HighAction: junkHighAction.value,
 				Onegrams: junkOnegrams.checked,
 				Twograms: junkTwograms.checked,
 				Threegrams: acc.JunkFilter?.Threegrams || false,
@@ -1650,11 +2335,36 @@ openssl pkcs12 \\
 			return r;
 		};
 		await check(junkFilterFields, (async () => await client.JunkFilterSave(xjunkFilter()))());
-	}, junkFilterFields = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.label('Enabled', attr.title("If enabled, the junk filter is used to classify incoming email from first-time senders. The result, along with other checks, determines if the message will be accepted or rejected"), dom.div(junkFilterEnabled = dom.input(attr.type('checkbox'), acc.JunkFilter ? attr.checked('') : []))), dom.label('Threshold', attr.title('Approximate spaminess score between 0 and 1 above which emails are rejected as spam. Each delivery attempt adds a little noise to make it slightly harder for spammers to identify words that strongly indicate non-spaminess and use it to bypass the filter. E.g. 0.95.'), dom.div(junkThreshold = dom.input(attr.value('' + (acc.JunkFilter?.Threshold || '0.95'))))), dom.label('Onegrams', attr.title('Track ham/spam ranking for single words.'), dom.div(junkOnegrams = dom.input(attr.type('checkbox'), acc.JunkFilter?.Onegrams ? attr.checked('') : []))), dom.label('Twograms', attr.title('Track ham/spam ranking for each two consecutive words.'), dom.div(junkTwograms = dom.input(attr.type('checkbox'), acc.JunkFilter?.Twograms ? attr.checked('') : []))), dom.label('Threegrams', attr.title('Track ham/spam ranking for each three consecutive words. Can only be changed by admin.'), dom.div(dom.input(attr.type('checkbox'), attr.disabled(''), acc.JunkFilter?.Threegrams ? attr.checked('') : []))), dom.label('Max power', attr.title('Maximum power a word (combination) can have. If spaminess is 0.99, and max power is 0.1, spaminess of the word will be set to 0.9. Similar for ham words.'), dom.div(junkMaxPower = dom.input(attr.value('' + (acc.JunkFilter?.MaxPower || 0.01))))), dom.label('Top words', attr.title('Number of most spammy/hammy words to use for calculating probability. E.g. 10.'), dom.div(junkTopWords = dom.input(attr.value('' + (acc.JunkFilter?.TopWords || 10))))), dom.label('Ignore words', attr.title('Ignore words that are this much away from 0.5 haminess/spaminess. E.g. 0.1, causing word (combinations) of 0.4 to 0.6 to be ignored.'), dom.div(junkIgnoreWords = dom.input(attr.value('' + (acc.JunkFilter?.IgnoreWords || 0.1))))), dom.label('Rare words', attr.title('Occurrences in word database until a word is considered rare and its influence in calculating probability reduced. E.g. 1 or 2.'), dom.div(junkRareWords = dom.input(attr.value('' + (acc.JunkFilter?.RareWords || 2))))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save')))))), dom.br(), dom.h2('Rejects'), dom.form(async function submit(e) {
+	}, junkFilterFields = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.label('Enabled', attr.title("If enabled, the junk filter is used to classify incoming email from first-time senders. The result, along with other checks, determines if the message will be accepted or rejected"), dom.div(junkFilterEnabled = dom.input(attr.type('checkbox'), acc.JunkFilter ? attr.checked('') : []))), dom.label('Threshold', attr.title('Approximate spaminess score between 0 and 1 above which emails are rejected as spam. Each delivery attempt adds a little noise to make it slightly harder for spammers to identify words that strongly indicate non-spaminess and use it to bypass the filter. E.g. 0.95.'), dom.div(junkThreshold = dom.input(attr.value('' + (acc.JunkFilter?.Threshold || '0.95'))))), dom.label('Tag threshold', attr.title('Spaminess score between 0 and 1 above which accepted messages get X-Mox-Spam-Flag and X-Mox-Spam-Score headers, e.g. for filtering in mail clients. E.g. 0.5. Empty for the default of the domain of the account.'), dom.div(junkTagThreshold = dom.input(attr.value(acc.JunkFilter?.TagThreshold ? '' + acc.JunkFilter.TagThreshold : '')))), dom.label('Junk threshold', attr.title('Spaminess score between 0 and 1 above which messages are accepted but delivered to the Junk mailbox. Should be below Threshold. E.g. 0.8. Empty for the default of the domain of the account.'), dom.div(junkJunkThreshold = dom.input(attr.value(acc.JunkFilter?.JunkThreshold ? '' + acc.JunkFilter.JunkThreshold : '')))), dom.label('Above threshold', attr.title('Action for messages with a spaminess score above Threshold. Reject rejects the message during the SMTP transaction. Quarantine accepts the message, but only stores it in the rejects mailbox, which must be configured below.'), dom.div(junkHighAction = dom.select(dom.option('Default', attr.value('')), dom.option('Reject', attr.value('reject'), acc.JunkFilter?.HighAction === 'reject' ? attr.selected('') : []), dom.option('Quarantine', attr.value('quarantine'), acc.JunkFilter?.HighAction === 'quarantine' ? attr.selected('') : [])))), dom.label('Onegrams', attr.title('Track ham/spam ranking for single words.'), dom.div(junkOnegrams = dom.input(attr.type('checkbox'), acc.JunkFilter?.Onegrams ? attr.checked('') : []))), dom.label('Twograms', attr.title('Track ham/spam ranking for each two consecutive words.'), dom.div(junkTwograms = dom.input(attr.type('checkbox'), acc.JunkFilter?.Twograms ? attr.checked('') : []))), dom.label('Threegrams', attr.title('Track ham/spam ranking for each three consecutive words. Can only be changed by admin.'), dom.div(dom.input(attr.type('checkbox'), attr.disabled(''), acc.JunkFilter?.Threegrams ? attr.checked('') : []))), dom.label('Max power', attr.title('Maximum power a word (combination) can have. If spaminess is 0.99, and max power is 0.1, spaminess of the word will be set to 0.9. Similar for ham words.'), dom.div(junkMaxPower = dom.input(attr.value('' + (acc.JunkFilter?.MaxPower || 0.01))))), dom.label('Top words', attr.title('Number of most spammy/hammy words to use for calculating probability. E.g. 10.'), dom.div(junkTopWords = dom.input(attr.value('' + (acc.JunkFilter?.TopWords || 10))))), dom.label('Ignore words', attr.title('Ignore words that are this much away from 0.5 haminess/spaminess. E.g. 0.1, causing word (combinations) of 0.4 to 0.6 to be ignored.'), dom.div(junkIgnoreWords = dom.input(attr.value('' + (acc.JunkFilter?.IgnoreWords || 0.1))))), dom.label('Rare words', attr.title('Occurrences in word database until a word is considered rare and its influence in calculating probability reduced. E.g. 1 or 2.'), dom.div(junkRareWords = dom.input(attr.value('' + (acc.JunkFilter?.RareWords || 2))))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save')))))), dom.br(), dom.h2('Rejects'), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(rejectsFieldset, client.RejectsSave(rejectsMailbox.value, keepRejects.checked));
-	}, rejectsFieldset = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.label('Mailbox', attr.title("Mail that looks like spam will be rejected, but a copy can be stored temporarily in a mailbox, e.g. Rejects. If mail isn't coming in when you expect, you can look there. The mail still isn't accepted, so the remote mail server may retry (hopefully, if legitimate), or give up (hopefully, if indeed a spammer). Messages are automatically removed from this mailbox, so do not set it to a mailbox that has messages you want to keep."), dom.div(rejectsMailbox = dom.input(attr.value(acc.RejectsMailbox)))), dom.label("No cleanup", attr.title("Don't automatically delete mail in the RejectsMailbox listed above. This can be useful, e.g. for future spam training. It can also cause storage to fill up."), dom.div(keepRejects = dom.input(attr.type('checkbox'), acc.KeepRejects ? attr.checked('') : []))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save')))))), dom.br(), dom.h2('Webhooks'), dom.h3('Outgoing', attr.title('Webhooks for outgoing messages are called for each attempt to deliver a message in the outgoing queue, e.g. when the queue has delivered a message to the next hop, when a single attempt failed with a temporary error, when delivery permanently failed, or when DSN (delivery status notification) messages were received about a previously sent message.')), dom.form(async function submit(e) {
+	}, rejectsFieldset = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.label('Mailbox', attr.title("Mail that looks like spam will be rejected, but a copy can be stored temporarily in a mailbox, e.g. Rejects. If mail isn't coming in when you expect, you can look there. The mail still isn't accepted, so the remote mail server may retry (hopefully, if legitimate), or give up (hopefully, if indeed a spammer). Messages are automatically removed from this mailbox, so do not set it to a mailbox that has messages you want to keep."), dom.div(rejectsMailbox = dom.input(attr.value(acc.RejectsMailbox)))), dom.label("No cleanup", attr.title("Don't automatically delete mail in the RejectsMailbox listed above. This can be useful, e.g. for future spam training. It can also cause storage to fill up."), dom.div(keepRejects = dom.input(attr.type('checkbox'), acc.KeepRejects ? attr.checked('') : []))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save')))))), dom.br(), dom.h2('Screening', attr.title('With screening, messages from senders you have not exchanged messages with before are delivered to a screening mailbox instead of the Inbox. Moving a message out of the screening mailbox, to a mailbox other than Junk or Trash, approves the sender by adding it to the sender allow list below. Messages matched by rulesets, forwarded messages and mailing list messages are not screened.')), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(screeningFieldset, client.ScreeningSave(screeningEnabled.checked ? { Mailbox: screeningMailbox.value } : null));
+	}, screeningFieldset = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.label('Enabled', dom.div(screeningEnabled = dom.input(attr.type('checkbox'), acc.Screening ? attr.checked('') : []))), dom.label('Mailbox', attr.title('Mailbox for messages from first-time senders awaiting approval, created when needed. Default: Screening.'), dom.div(screeningMailbox = dom.input(attr.value(acc.Screening?.Mailbox || ''), attr.placeholder('Screening')))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save')))))), dom.br(), dom.h2('Categories', attr.title('Incoming bulk messages that are not spam can be categorized as newsletters, notifications or updates from social networks, and delivered to a separate mailbox, or to the usual mailbox with a keyword ($newsletter, $notification or $social). Categorization does not influence junk filtering. Messages matched by rulesets, and messages delivered to the screening mailbox, are not categorized. Messages from verified sender domains configured for a category are always in that category.')), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const categories = {
+			Newsletters: categoryValue(0),
+			Notifications: categoryValue(1),
+			Social: categoryValue(2),
+		};
+		const enabled = categories.Newsletters || categories.Notifications || categories.Social;
+		await check(categoriesFieldset, client.CategoriesSave(enabled ? categories : null));
+	}, categoriesFieldset = dom.fieldset(dom.table(dom.thead(dom.tr(dom.th('Category'), dom.th('Mailbox', attr.title('Mailbox to deliver messages in this category to, created when needed. If empty, messages are delivered to the usual mailbox, with only the keyword set.')), dom.th('Sender domains', attr.title('Comma-separated sender domains whose messages are always in this category, including subdomains, if verified through the DMARC-aligned message From address or a DKIM signature. E.g. github.com for notifications.')))), dom.tbody(categoryRow('Newsletters', 'Newsletters, marketing and mailing list messages, recognized by mailing list headers (List-Id, List-Unsubscribe) or bulk precedence.', acc.Categories?.Newsletters), categoryRow('Notifications', 'Automated messages like receipts and alerts, recognized by an Auto-Submitted header or a sender like noreply@.', acc.Categories?.Notifications), categoryRow('Social', 'Updates from social networks, recognized by a well-known social network sender domain, or the configured sender domains.', acc.Categories?.Social))), dom.br(), dom.submitbutton('Save'))), dom.br(), dom.h2('Sender lists', attr.title('Block or allow messages from senders, by address (user@example.org), domain (example.org), or domain including subdomains (*.example.org). Messages from rejected senders are refused during the SMTP transaction. Messages from discarded senders are accepted but not delivered. Messages from allowed senders bypass junk filtering, but only if the message From address is verified with SPF/DKIM/DMARC. Rejects and discards are matched against both the message From address and the SMTP MAIL FROM address. The most specific entry applies.')), dom.form(attr.id('senderListAdd'), async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(e.target, client.SenderListAdd(senderListPattern.value, senderListAction.value));
+		window.location.reload(); // todo: reload less
+	}), dom.table(dom.thead(dom.tr(dom.th('Address or domain'), dom.th('Action'), dom.th('Since'), dom.th())), dom.tbody(senderList.length === 0 ? dom.tr(dom.td(attr.colspan('4'), '(None)')) : [], senderList.map(e => dom.tr(dom.td(e.Pattern), dom.td(dom.select(async function change(ev) {
+		const sel = ev.target;
+		await check(sel, client.SenderListAdd(e.Pattern, sel.value));
+	}, Object.values(api.SenderListAction).map(a => dom.option(a, attr.value(a), a === e.Action ? attr.selected('') : [])))), dom.td(age(e.Created)), dom.td(dom.clickbutton('Remove', async function click(ev) {
+		await check(ev.target, client.SenderListRemove(e.ID));
+		window.location.reload(); // todo: reload less
+	}))))), dom.tfoot(dom.tr(dom.td(senderListPattern = dom.input(attr.required(''), attr.placeholder('user@example.org, example.org or *.example.org'), attr.form('senderListAdd'))), dom.td(senderListAction = dom.select(attr.form('senderListAdd'), Object.values(api.SenderListAction).map(a => dom.option(a, attr.value(a), a === api.SenderListAction.SenderListReject ? attr.selected('') : [])))), dom.td(), dom.td(dom.submitbutton('Add', attr.form('senderListAdd')))))), dom.br(), dom.div(featureHidden('webhooks'), dom.h2('Webhooks'), dom.h3('Outgoing', attr.title('Webhooks for outgoing messages are called for each attempt to deliver a message in the outgoing queue, e.g. when the queue has delivered a message to the next hop, when a single attempt failed with a temporary error, when delivery permanently failed, or when DSN (delivery status notification) messages were received about a previously sent message.')), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(outgoingWebhookFieldset, client.OutgoingWebhookSave(outgoingWebhookURL.value, outgoingWebhookAuthorization.value, [...outgoingWebhookEvents.selectedOptions].map(o => o.value)));
@@ -1673,7 +2383,7 @@ openssl pkcs12 \\
 		authorizationPopup(incomingWebhookAuthorization);
 	}), attr.title('If non-empty, HTTP requests have this value as Authorization header, e.g. Basic <base64-encoded-username-password>.')), incomingWebhookAuthorization = dom.input(attr.value(acc.IncomingWebhook?.Authorization || '')))), dom.div(dom.div(dom.label('\u00a0')), dom.submitbutton('Save'), ' ', dom.clickbutton('Test', function click() {
 		popupTestIncoming();
-	}))))), dom.br(), dom.h2('Keep messages/webhooks retired from queue', attr.title('After delivering a message or webhook from the queue it is removed by default. But you can also keep these "retired" messages/webhooks around for a while. With unique SMTP MAIL FROM addresses configured below, this allows relating incoming delivery status notification messages (DSNs) to previously sent messages and their original recipients, which is needed for automatic management of recipient suppression lists, which is important for managing the reputation of your mail server. For both messages and webhooks, this can be useful for debugging. Use values like "3d" for 3 days, or units "s" for second, "m" for minute, "h" for hour, "w" for week.')), dom.form(async function submit(e) {
+	}))))), dom.br()), dom.h2('Keep messages/webhooks retired from queue', attr.title('After delivering a message or webhook from the queue it is removed by default. But you can also keep these "retired" messages/webhooks around for a while. With unique SMTP MAIL FROM addresses configured below, this allows relating incoming delivery status notification messages (DSNs) to previously sent messages and their original recipients, which is needed for automatic management of recipient suppression lists, which is important for managing the reputation of your mail server. For both messages and webhooks, this can be useful for debugging. Use values like "3d" for 3 days, or units "s" for second, "m" for minute, "h" for hour, "w" for week.')), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(keepRetiredPeriodsFieldset, (async () => await client.KeepRetiredPeriodsSave(parseDuration(keepRetiredMessagePeriod.value), parseDuration(keepRetiredWebhookPeriod.value)))());
@@ -1711,10 +2421,10 @@ openssl pkcs12 \\
 		e.stopPropagation();
 		await check(e.target, client.SuppressionAdd(suppressionAddress.value, true, suppressionReason.value));
 		window.location.reload(); // todo: reload less
-	}), dom.table(dom.thead(dom.tr(dom.th('Address', attr.title('Address that caused this entry to be added to the list. The title (shown on hover) displays an address with a fictional simplified localpart, with lower-cased, dots removed, only first part before "+" or "-" (typicaly catchall separators). When checking if an address is on the suppression list, it is checked against this address.')), dom.th('Manual', attr.title('Whether suppression was added manually, instead of automatically based on bounces.')), dom.th('Reason'), dom.th('Since'), dom.th('Action'))), dom.tbody((suppressions || []).length === 0 ? dom.tr(dom.td(attr.colspan('5'), '(None)')) : [], (suppressions || []).map(s => dom.tr(dom.td(prewrap(s.OriginalAddress), attr.title(s.BaseAddress)), dom.td(s.Manual ? '✓' : ''), dom.td(s.Reason), dom.td(age(s.Created)), dom.td(dom.clickbutton('Remove', async function click(e) {
+	}), dom.table(dom.thead(dom.tr(dom.th('Address', attr.title('Address that caused this entry to be added to the list. The title (shown on hover) displays an address with a fictional simplified localpart, with lower-cased, dots removed, only first part before "+" or "-" (typicaly catchall separators). When checking if an address is on the suppression list, it is checked against this address.')), dom.th('Manual', attr.title('Whether suppression was added manually, instead of automatically based on bounces.')), dom.th('Class', attr.title('For automatically added suppressions, the classification of the delivery failure: hard, soft, block or policy.')), dom.th('Reason'), dom.th('Since'), dom.th('Action'))), dom.tbody((suppressions || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), '(None)')) : [], (suppressions || []).map(s => dom.tr(dom.td(prewrap(s.OriginalAddress), attr.title(s.BaseAddress)), dom.td(s.Manual ? '✓' : ''), dom.td(s.BounceClass), dom.td(s.Reason), dom.td(age(s.Created)), dom.td(dom.clickbutton('Remove', async function click(e) {
 		await check(e.target, client.SuppressionRemove(s.OriginalAddress));
 		window.location.reload(); // todo: reload less
-	}))))), dom.tfoot(dom.tr(dom.td(suppressionAddress = dom.input(attr.type('required'), attr.form('suppressionAdd'))), dom.td(), dom.td(suppressionReason = dom.input(style({ width: '100%' }), attr.form('suppressionAdd'))), dom.td(), dom.td(dom.submitbutton('Add suppression', attr.form('suppressionAdd')))))), dom.br(), dom.h2('Export'), dom.p('Export all messages in all mailboxes.'), dom.form(attr.target('_blank'), attr.method('POST'), attr.action('export'), dom.input(attr.type('hidden'), attr.name('csrf'), attr.value(localStorageGet('webaccountcsrftoken') || '')), dom.input(attr.type('hidden'), attr.name('mailbox'), attr.value('')), dom.input(attr.type('hidden'), attr.name('recursive'), attr.value('on')), dom.div(style({ display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.div(dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('maildir'), attr.checked('')), ' Maildir'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('mbox')), ' Mbox')), dom.div(dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tar')), ' Tar'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tgz'), attr.checked('')), ' Tgz'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('zip')), ' Zip'), ' '), dom.div(style({ marginTop: '1ex' }), dom.submitbutton('Export')))), dom.br(), dom.h2('Import'), dom.p('Import messages from a .zip or .tgz file with maildirs and/or mbox files.'), importForm = dom.form(async function submit(e) {
+	}))))), dom.tfoot(dom.tr(dom.td(suppressionAddress = dom.input(attr.type('required'), attr.form('suppressionAdd'))), dom.td(), dom.td(), dom.td(suppressionReason = dom.input(style({ width: '100%' }), attr.form('suppressionAdd'))), dom.td(), dom.td(dom.submitbutton('Add suppression', attr.form('suppressionAdd')))))), dom.br(), dom.div(featureHidden('export'), dom.h2('Export'), dom.p('Export all messages in all mailboxes.'), dom.form(attr.target('_blank'), attr.method('POST'), attr.action('export'), dom.input(attr.type('hidden'), attr.name('csrf'), attr.value(localStorageGet('webaccountcsrftoken') || '')), dom.input(attr.type('hidden'), attr.name('mailbox'), attr.value('')), dom.input(attr.type('hidden'), attr.name('recursive'), attr.value('on')), dom.div(style({ display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.div(dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('maildir'), attr.checked('')), ' Maildir'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('maildir++')), ' Maildir++ (Dovecot)', attr.title('Maildir++ layout with dovecot-uidlist and subscriptions files, for use as Dovecot mail store.')), ' ', dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('mbox')), ' Mbox')), dom.div(dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tar')), ' Tar'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tgz'), attr.checked('')), ' Tgz'), ' ', dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('zip')), ' Zip'), ' '), dom.div(style({ marginTop: '1ex' }), dom.submitbutton('Export')))), dom.br()), dom.div(featureHidden('import'), dom.h2('Import'), dom.p('Import messages from a .zip or .tgz file with maildirs and/or mbox files.'), importForm = dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		const request = async () => {
@@ -1779,7 +2489,7 @@ openssl pkcs12 \\
 	})), mailboxFileHint = dom.p(style({ display: 'none', fontStyle: 'italic', marginTop: '.5ex' }), 'This file must either be a zip file or a gzipped tar file with mbox and/or maildir mailboxes. For maildirs, an optional file "dovecot-keywords" is read additional keywords, like Forwarded/Junk/NotJunk. If an imported mailbox already exists by name, messages are added to the existing mailbox. If a mailbox does not yet exist it will be created. Messages are not deduplicated, importing them twice will result in duplicates.')), dom.div(style({ marginBottom: '1ex' }), dom.label(dom.div(style({ marginBottom: '.5ex' }), 'Skip mailbox prefix (optional)'), dom.input(attr.name('skipMailboxPrefix'), function focus() {
 		mailboxPrefixHint.style.display = '';
 	})), mailboxPrefixHint = dom.p(style({ display: 'none', fontStyle: 'italic', marginTop: '.5ex' }), 'If set, any mbox/maildir path with this prefix will have it stripped before importing. For example, if all mailboxes are in a directory "Takeout", specify that path in the field above so mailboxes like "Takeout/Inbox.mbox" are imported into a mailbox called "Inbox" instead of "Takeout/Inbox".')), dom.div(dom.submitbutton('Upload and import'), dom.p(style({ fontStyle: 'italic', marginTop: '.5ex' }), 'The file is uploaded first, then its messages are imported, finally messages are matched for threading. Importing is done in a transaction, you can abort the entire import before it is finished.')))), importAbortBox = dom.div(), // Outside fieldset because it gets disabled, above progress because may be scrolling it down quickly with problems.
-	importProgress = dom.div(style({ display: 'none' })), dom.br(), footer());
+	importProgress = dom.div(style({ display: 'none' })), dom.br()), footer());
 	(async () => {
 		// Try to show the progress of an earlier import session. The user may have just
 		// refreshed the browser.
@@ -1815,9 +2525,120 @@ const renderLoginAttempts = (loginAttempts) => {
 	// todo: pagination and search
 	return dom.table(dom.thead(dom.tr(dom.th('Time'), dom.th('Result'), dom.th('Count'), dom.th('LoginAddress'), dom.th('Protocol'), dom.th('Mechanism'), dom.th('User Agent'), dom.th('Remote IP'), dom.th('Local IP'), dom.th('TLS'), dom.th('TLS pubkey fingerprint'), dom.th('First seen'))), dom.tbody(loginAttempts.length ? [] : dom.tr(dom.td(attr.colspan('11'), 'No login attempts in past 30 days.')), loginAttempts.map(la => dom.tr(dom.td(age(la.Last)), dom.td(la.Result === 'ok' ? la.Result : box(red, la.Result)), dom.td('' + la.Count), dom.td(la.LoginAddress), dom.td(la.Protocol), dom.td(la.AuthMech), dom.td(la.UserAgent), dom.td(la.RemoteIP), dom.td(la.LocalIP), dom.td(la.TLS), dom.td(la.TLSPubKeyFingerprint), dom.td(age(la.First))))));
 };
+// Render active sessions, with buttons to revoke them.
+const renderTwoFactor = (status0) => {
+	let status = status0;
+	let elem;
+	const reload = async () => {
+		status = await client.TwoFactorStatus();
+		const e = render();
+		elem.replaceWith(e);
+		elem = e;
+	};
+	const renderSetup = (setup) => {
+		let fieldset;
+		let code;
+		return dom.div(dom.p('Scan the QR code with your authenticator app, or enter the secret manually. Then enter the code shown by the app to confirm.'), dom.img(attr.src(setup.QRCodePNG), attr.title(setup.URL)), dom.p('Secret: ', dom.span(dom._class('literal'), setup.Secret)), dom.form(fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Code', dom.br(), code = dom.input(attr.required(''), attr.autocomplete('one-time-code'))), ' ', dom.submitbutton('Confirm')), async function submit(e) {
+			e.stopPropagation();
+			e.preventDefault();
+			await check(fieldset, client.TOTPSetupConfirm(code.value));
+			window.alert('Two-factor authentication is now enabled. Use app passwords for IMAP/SMTP clients.');
+			await reload();
+		}));
+	};
+	let setupBox;
+	let appName;
+	let appFieldset;
+	let appProtocols;
+	let appIPRanges;
+	const render = () => dom.div(status.Required && !status.Enabled ? box(yellow, 'Two-factor authentication is required for your account. Until it is enabled, you can only login to this account web interface.') : [], status.Enabled ?
+		dom.p('Enabled. ', status.Required ? [] : dom.clickbutton('Disable', attr.title('Disable two-factor authentication, after entering a current code from your authenticator app. App passwords are kept.'), async function click(e) {
+			const code = window.prompt('Code from authenticator app');
+			if (!code) {
+				return;
+			}
+			await check(e.target, client.TOTPRemove(code));
+			await reload();
+		})) :
+		setupBox = dom.div(dom.p('Not enabled.'), dom.clickbutton('Set up two-factor authentication', async function click(e) {
+			const setup = await check(e.target, client.TOTPSetupStart());
+			dom._kids(setupBox, renderSetup(setup));
+		})), dom.h3('App passwords', attr.title('Generated passwords for applications that cannot do two-factor authentication, like IMAP/SMTP email clients. Each app password is only valid for the selected protocols, and optionally only from the given IP ranges. App passwords can also be used without two-factor authentication.')), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Protocols'), dom.th('IP ranges'), dom.th('Created'), dom.th('Last used'), dom.th('Action'))), dom.tbody((status.AppPasswords || []).length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No app passwords.')), (status.AppPasswords || []).map(ap => dom.tr(dom.td(ap.Name), dom.td((ap.Protocols || []).join(', ')), dom.td((ap.IPRanges || []).join(', ') || 'any'), dom.td(age(ap.Created)), dom.td(ap.LastUsed.getTime() > 0 ? [age(ap.LastUsed), ap.LastIP ? ', from ' + ap.LastIP : ''] : 'never'), dom.td(dom.clickbutton('Remove', async function click(e) {
+		if (!window.confirm('Are you sure? Applications using this password can no longer login.')) {
+			return;
+		}
+		await check(e.target, client.AppPasswordRemove(ap.ID));
+		await reload();
+	})))))), dom.br(), dom.form(appFieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Name', dom.br(), appName = dom.input(attr.required(''), attr.placeholder('Laptop email client'))), ' ', (appProtocols = ['imap', 'submission', 'webapi', 'caldav', 'carddav'].map(protocol => ({ protocol: protocol, checkbox: dom.input(attr.type('checkbox'), protocol === 'imap' || protocol === 'submission' ? attr.checked('') : []) }))).map(p => [dom.label(p.checkbox, ' ', p.protocol), ' ']), dom.label(style({ display: 'inline-block' }), 'IP ranges', attr.title('Optional. If set, the app password can only be used from these networks, in CIDR notation or as single IPs, separated by commas or spaces.'), dom.br(), appIPRanges = dom.input(attr.placeholder('192.0.2.0/24, 2001:db8::/32'))), ' ', dom.submitbutton('Add app password')), async function submit(e) {
+		e.stopPropagation();
+		e.preventDefault();
+		const protocols = appProtocols.filter(p => p.checkbox.checked).map(p => p.protocol);
+		const ipRanges = appIPRanges.value.split(/[\s,]+/).filter(s => s);
+		const password = await check(appFieldset, client.AppPasswordAdd(appName.value, protocols, ipRanges));
+		window.alert('New app password: ' + password + '\n\nConfigure it in the application. It cannot be shown again.');
+		await reload();
+	}));
+	elem = render();
+	return elem;
+};
+const renderPasskeys = (passkeys0) => {
+	let passkeys = passkeys0;
+	let elem;
+	const reload = async () => {
+		passkeys = await client.Passkeys() || [];
+		const e = render();
+		elem.replaceWith(e);
+		elem = e;
+	};
+	let fieldset;
+	let name;
+	const render = () => dom.div(dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Created'), dom.th('Last used'), dom.th('Action'))), dom.tbody(passkeys.length ? [] : dom.tr(dom.td(attr.colspan('4'), 'No passkeys.')), passkeys.map(pk => dom.tr(dom.td(pk.Name), dom.td(age(pk.Created)), dom.td(pk.LastUsed.getTime() > 0 ? age(pk.LastUsed) : 'never'), dom.td(dom.clickbutton('Remove', async function click(e) {
+		if (!window.confirm('Are you sure? The passkey can no longer be used to login. You may also want to remove it from your device or password manager.')) {
+			return;
+		}
+		await check(e.target, client.PasskeyRemove(pk.CredentialID));
+		await reload();
+	})))))), dom.br(), window.PublicKeyCredential ?
+		dom.form(fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Name', dom.br(), name = dom.input(attr.required(''), attr.placeholder('Phone'))), ' ', dom.submitbutton('Add passkey')), async function submit(e) {
+			e.stopPropagation();
+			e.preventDefault();
+			await check(fieldset, (async () => {
+				const opts = await client.PasskeyRegisterStart();
+				const [clientDataJSON, attestationObject] = await passkeyCreate(opts);
+				await client.PasskeyRegister(name.value, clientDataJSON, attestationObject);
+			})());
+			await reload();
+		}) :
+		dom.p('Your browser does not support passkeys.'));
+	elem = render();
+	return elem;
+};
+const renderSessions = (sessions0) => {
+	let sessions = sessions0;
+	let elem;
+	const reload = async () => {
+		sessions = await client.Sessions() || [];
+		const e = render();
+		elem.replaceWith(e);
+		elem = e;
+	};
+	const render = () => dom.div(dom.table(dom.thead(dom.tr(dom.th('Protocol'), dom.th('LoginAddress'), dom.th('Remote IP'), dom.th('User Agent'), dom.th('Started'), dom.th('Last activity'), dom.th('Action'))), dom.tbody(sessions.length ? [] : dom.tr(dom.td(attr.colspan('7'), 'No sessions.')), sessions.map(s => dom.tr(dom.td(s.Protocol || 'web'), dom.td(s.LoginAddress), dom.td(s.RemoteIP), dom.td(s.UserAgent), dom.td(age(s.Started)), dom.td(age(s.LastActivity)), dom.td(s.Current ? 'Current session' : dom.clickbutton('Revoke', attr.title(s.Web ? 'Log out this session.' : 'Close this connection. Clients with a stored password can connect again.'), async function click(e) {
+		await check(e.target, client.SessionRevoke(s.Web, s.ID));
+		await reload();
+	})))))), dom.br(), dom.clickbutton('Revoke all other sessions', attr.title('Log out all other web sessions and close all IMAP and submission connections. Clients with a stored password can connect again, change the password to prevent that.'), async function click(e) {
+		if (!window.confirm('Are you sure you want to revoke all other sessions?')) {
+			return;
+		}
+		const n = await check(e.target, client.SessionsRevokeAll());
+		window.alert(n + ' session(s) revoked.');
+		await reload();
+	}));
+	elem = render();
+	return elem;
+};
 const loginattempts = async () => {
 	const loginAttempts = await client.LoginAttempts(0);
-	return dom.div(crumbs(crumblink('Mox Account', '#'), 'Login attempts'), dom.h2('Login attempts'), dom.p('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored to prevent unlimited growth of the database.'), renderLoginAttempts(loginAttempts || []));
+	return dom.div(crumbs(crumblink(branding.Name || 'Mox Account', '#'), 'Login attempts'), dom.h2('Login attempts'), dom.p('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored to prevent unlimited growth of the database.'), renderLoginAttempts(loginAttempts || []));
 };
 const destination = async (name) => {
 	const [acc] = await client.Account();
@@ -1895,7 +2716,7 @@ const destination = async (name) => {
 	let msgAuthRequiredSMTPError;
 	let saveButton;
 	const addresses = [name, ...Object.keys(acc.Destinations || {}).filter(a => !a.startsWith('@') && a !== name)];
-	return dom.div(crumbs(crumblink('Mox Account', '#'), 'Destination ' + name), dom.div(dom.span('Default mailbox', attr.title('Default mailbox where email for this recipient is delivered to if it does not match any ruleset. Default is Inbox.')), dom.br(), defaultMailbox = dom.input(attr.value(dest.Mailbox), attr.placeholder('Inbox'))), dom.br(), dom.div(dom.span('Full name', attr.title('Name to use in From header when composing messages. If not set, the account default full name is used.')), dom.br(), fullName = dom.input(attr.value(dest.FullName))), dom.br(), dom.div(dom.span('Reject deliveries with SMTP Error', attr.title('If non-empty, incoming delivery attempts to this destination will be rejected during SMTP RCPT TO with this error response line. The response line must start with an error code. Currently the following error resonse codes are allowed: 421 (temporary local error), 550 (mailbox not found). If the line consists of only an error code, an appropriate error message is added. Rejecting messages with a 4xx code invites later retries by the remote, while 5xx codes should prevent further delivery attempts.')), dom.br(), smtpError = dom.input(attr.value(dest.SMTPError), attr.placeholder('421 or 550...'))), dom.br(), dom.div(dom.span('Reject messages without authenticated domain (aligned SPF/DKIM)', attr.title("If non-empty, an additional DMARC-like message authentication check is done for incoming messages, validating the domain in the From-header of the message. Messages without either an aligned SPF or aligned DKIM pass are rejected during the SMTP DATA command with a permanent error code followed by the message in this field. The domain in the message 'From' header is matched in relaxed or strict mode according to the domain's DMARC policy if present, or relaxed mode (organizational instead of exact domain match) otherwise. Useful for autoresponders that don't want to accept messages they don't want to send an automated reply to.")), dom.br(), msgAuthRequiredSMTPError = dom.input(attr.value(dest.MessageAuthRequiredSMTPError), attr.placeholder('messages must have aligned spf/dkim for domain authentication...'))), dom.br(), dom.h2('Rulesets'), dom.p('Incoming messages are checked against the rulesets. If a ruleset matches, the message is delivered to the mailbox configured for the ruleset instead of to the default mailbox.'), dom.p('"Is Forward" does not affect matching, but changes prevents the sending mail server from being included in future junk classifications by clearing fields related to the forwarding email server (IP address, EHLO domain, MAIL FROM domain and a matching DKIM domain), and prevents DMARC rejects for forwarded messages.'), dom.p('"List allow domain" does not affect matching, but skips the regular spam checks if one of the verified domains is a (sub)domain of the domain mentioned here.'), dom.p('"Accept rejects to mailbox" does not affect matching, but causes messages classified as junk to be accepted and delivered to this mailbox, instead of being rejected during the SMTP transaction. Useful for incoming forwarded messages where rejecting incoming messages may cause the forwarding server to stop forwarding.'), dom.table(dom.thead(dom.tr(dom.th('SMTP "MAIL FROM" regexp', attr.title('Matches if this regular expression matches (a substring of) the SMTP MAIL FROM address (not the message From-header). E.g. user@example.org.')), dom.th('Message "From" address regexp', attr.title('Matches if this regular expression matches (a substring of) the single address in the message From header.')), dom.th('Verified domain', attr.title('Matches if this domain matches an SPF- and/or DKIM-verified (sub)domain.')), dom.th('Headers regexp', attr.title('Matches if these header field/value regular expressions all match (substrings of) the message headers. Header fields and valuees are converted to lower case before matching. Whitespace is trimmed from the value before matching. A header field can occur multiple times in a message, only one instance has to match. For mailing lists, you could match on ^list-id$ with the value typically the mailing list address in angled brackets with @ replaced with a dot, e.g. <name\\.lists\\.example\\.org>.')), dom.th('Is Forward', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. Can only be used together with SMTPMailFromRegexp and VerifiedDomain. SMTPMailFromRegexp must be set to the address used to deliver the forwarded message, e.g. '^user(|\\+.*)@forward\\.example$'. Changes to junk analysis: 1. Messages are not rejected for failing a DMARC policy, because a legitimate forwarded message without valid/intact/aligned DKIM signature would be rejected because any verified SPF domain will be 'unaligned', of the forwarding mail server. 2. The sending mail server IP address, and sending EHLO and MAIL FROM domains and matching DKIM domain aren't used in future reputation-based spam classifications (but other verified DKIM domains are) because the forwarding server is not a useful spam signal for future messages.")), dom.th('List allow domain', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. If this domain matches an SPF- and/or DKIM-verified (sub)domain, the message is accepted without further spam checks, such as a junk filter or DMARC reject evaluation. DMARC rejects should not apply for mailing lists that are not configured to rewrite the From-header of messages that don't have a passing DKIM signature of the From-domain. Otherwise, by rejecting messages, you may be automatically unsubscribed from the mailing list. The assumption is that mailing lists do their own spam filtering/moderation.")), dom.th('Allow rejects to mailbox', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. If a message is classified as spam, it isn't rejected during the SMTP transaction (the normal behaviour), but accepted during the SMTP transaction and delivered to the specified mailbox. The specified mailbox is not automatically cleaned up like the account global Rejects mailbox, unless set to that Rejects mailbox.")), dom.th('Mailbox', attr.title('Mailbox to deliver to if this ruleset matches.')), dom.th('Comment', attr.title('Free-form comments.')), dom.th('Action'))), rulesetsTbody, dom.tfoot(dom.tr(dom.td(attr.colspan('9')), dom.td(dom.clickbutton('Add ruleset', function click() {
+	return dom.div(crumbs(crumblink(branding.Name || 'Mox Account', '#'), 'Destination ' + name), dom.div(dom.span('Default mailbox', attr.title('Default mailbox where email for this recipient is delivered to if it does not match any ruleset. Default is Inbox.')), dom.br(), defaultMailbox = dom.input(attr.value(dest.Mailbox), attr.placeholder('Inbox'))), dom.br(), dom.div(dom.span('Full name', attr.title('Name to use in From header when composing messages. If not set, the account default full name is used.')), dom.br(), fullName = dom.input(attr.value(dest.FullName))), dom.br(), dom.div(dom.span('Reject deliveries with SMTP Error', attr.title('If non-empty, incoming delivery attempts to this destination will be rejected during SMTP RCPT TO with this error response line. The response line must start with an error code. Currently the following error resonse codes are allowed: 421 (temporary local error), 550 (mailbox not found). If the line consists of only an error code, an appropriate error message is added. Rejecting messages with a 4xx code invites later retries by the remote, while 5xx codes should prevent further delivery attempts.')), dom.br(), smtpError = dom.input(attr.value(dest.SMTPError), attr.placeholder('421 or 550...'))), dom.br(), dom.div(dom.span('Reject messages without authenticated domain (aligned SPF/DKIM)', attr.title("If non-empty, an additional DMARC-like message authentication check is done for incoming messages, validating the domain in the From-header of the message. Messages without either an aligned SPF or aligned DKIM pass are rejected during the SMTP DATA command with a permanent error code followed by the message in this field. The domain in the message 'From' header is matched in relaxed or strict mode according to the domain's DMARC policy if present, or relaxed mode (organizational instead of exact domain match) otherwise. Useful for autoresponders that don't want to accept messages they don't want to send an automated reply to.")), dom.br(), msgAuthRequiredSMTPError = dom.input(attr.value(dest.MessageAuthRequiredSMTPError), attr.placeholder('messages must have aligned spf/dkim for domain authentication...'))), dom.br(), dom.h2('Rulesets'), dom.p('Incoming messages are checked against the rulesets. If a ruleset matches, the message is delivered to the mailbox configured for the ruleset instead of to the default mailbox.'), dom.p('"Is Forward" does not affect matching, but changes prevents the sending mail server from being included in future junk classifications by clearing fields related to the forwarding email server (IP address, EHLO domain, MAIL FROM domain and a matching DKIM domain), and prevents DMARC rejects for forwarded messages.'), dom.p('"List allow domain" does not affect matching, but skips the regular spam checks if one of the verified domains is a (sub)domain of the domain mentioned here.'), dom.p('"Accept rejects to mailbox" does not affect matching, but causes messages classified as junk to be accepted and delivered to this mailbox, instead of being rejected during the SMTP transaction. Useful for incoming forwarded messages where rejecting incoming messages may cause the forwarding server to stop forwarding.'), dom.table(dom.thead(dom.tr(dom.th('SMTP "MAIL FROM" regexp', attr.title('Matches if this regular expression matches (a substring of) the SMTP MAIL FROM address (not the message From-header). E.g. user@example.org.')), dom.th('Message "From" address regexp', attr.title('Matches if this regular expression matches (a substring of) the single address in the message From header.')), dom.th('Verified domain', attr.title('Matches if this domain matches an SPF- and/or DKIM-verified (sub)domain.')), dom.th('Headers regexp', attr.title('Matches if these header field/value regular expressions all match (substrings of) the message headers. Header fields and valuees are converted to lower case before matching. Whitespace is trimmed from the value before matching. A header field can occur multiple times in a message, only one instance has to match. For mailing lists, you could match on ^list-id$ with the value typically the mailing list address in angled brackets with @ replaced with a dot, e.g. <name\\.lists\\.example\\.org>.')), dom.th('Is Forward', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. Can only be used together with SMTPMailFromRegexp and VerifiedDomain. SMTPMailFromRegexp must be set to the address used to deliver the forwarded message, e.g. '^user(|\\+.*)@forward\\.example$'. Changes to junk analysis: 1. Messages are not rejected for failing a DMARC policy, because a legitimate forwarded message without valid/intact/aligned DKIM signature would be rejected because any verified SPF domain will be 'unaligned', of the forwarding mail server. 2. The sending mail server IP address, and sending EHLO and MAIL FROM domains and matching DKIM domain aren't used in future reputation-based spam classifications (but other verified DKIM domains are) because the forwarding server is not a useful spam signal for future messages.")), dom.th('List allow domain', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. If this domain matches an SPF- and/or DKIM-verified (sub)domain, the message is accepted without further spam checks, such as a junk filter or DMARC reject evaluation. DMARC rejects should not apply for mailing lists that are not configured to rewrite the From-header of messages that don't have a passing DKIM signature of the From-domain. Otherwise, by rejecting messages, you may be automatically unsubscribed from the mailing list. The assumption is that mailing lists do their own spam filtering/moderation.")), dom.th('Allow rejects to mailbox', attr.title("Influences spam filtering only, this option does not change whether a message matches this ruleset. If a message is classified as spam, it isn't rejected during the SMTP transaction (the normal behaviour), but accepted during the SMTP transaction and delivered to the specified mailbox. The specified mailbox is not automatically cleaned up like the account global Rejects mailbox, unless set to that Rejects mailbox.")), dom.th('Mailbox', attr.title('Mailbox to deliver to if this ruleset matches.')), dom.th('Comment', attr.title('Free-form comments.')), dom.th('Action'))), rulesetsTbody, dom.tfoot(dom.tr(dom.td(attr.colspan('9')), dom.td(dom.clickbutton('Add ruleset', function click() {
 		addRulesetsRow({
 			SMTPMailFromRegexp: '',
 			MsgFromRegexp: '',
@@ -5,6 +5,9 @@ declare let page: HTMLElement
 // From customization script.
 declare let moxBeforeDisplay: (webmailroot: HTMLElement) => void
 
+// Per-domain customization, from branding.js.
+const branding: {Name?: string, LoginText?: string, Logo?: boolean} = (window as any).moxBranding || {}
+
 let moxversion: string
 let moxgoos: string
 let moxgoarch: string
@@ -17,6 +20,27 @@ const login = async (reason: string) => {
 		let autosize: HTMLElement
 		let username: HTMLInputElement
 		let password: HTMLInputElement
+		let totpLabel: HTMLElement
+		let totp: HTMLInputElement
+		let sso: HTMLElement
+
+		const loggedIn = (token: string, address: string) => {
+			try {
+				if (address) {
+					window.localStorage.setItem('webaccountaddress', address)
+				} else {
+					window.localStorage.removeItem('webaccountaddress')
+				}
+				window.localStorage.setItem('webaccountcsrftoken', token)
+			} catch (err) {
+				console.log('saving csrf token in localStorage', err)
+			}
+			root.remove()
+			if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
+				origFocus.focus()
+			}
+			resolve(token)
+		}
 
 		const root = dom.div(
 			style({position: 'absolute', top: 0, right: 0, bottom: 0, left: 0, backgroundColor: '#eee', display: 'flex', alignItems: 'center', justifyContent: 'center', zIndex: '1', animation: 'fadein .15s ease-in'}),
@@ -35,27 +59,27 @@ const login = async (reason: string) => {
 							try {
 								fieldset.disabled = true
 								const loginToken = await client.LoginPrep()
-								const token = await client.Login(loginToken, username.value, password.value)
-								try {
-									window.localStorage.setItem('webaccountaddress', username.value)
-									window.localStorage.setItem('webaccountcsrftoken', token)
-								} catch (err) {
-									console.log('saving csrf token in localStorage', err)
-								}
-								root.remove()
-								if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
-									origFocus.focus()
-								}
-								resolve(token)
+								const token = await client.Login(loginToken, username.value, password.value, totp.value)
+								loggedIn(token, username.value)
 							} catch (err) {
 								console.log('login error', err)
+								if ((err as any).code === 'user:totpRequired') {
+									// Password is valid, ask for the code from the authenticator app.
+									totpLabel.style.display = 'block'
+									totp.required = true
+									fieldset.disabled = false
+									totp.focus()
+									return
+								}
 								window.alert('Error: ' + errmsg(err))
 							} finally {
 								fieldset.disabled = false
 							}
 						},
 						fieldset=dom.fieldset(
-							dom.h1('Account'),
+							dom.h1(branding.Name || 'Account'),
+							branding.Logo ? dom.div(style({textAlign: 'center', marginBottom: '2ex'}), dom.img(attr.src('branding/logo'), style({maxWidth: '20em', maxHeight: '6em'}))) : [],
+							branding.LoginText ? dom.div(style({marginBottom: '2ex', maxWidth: '30em', whiteSpace: 'pre-wrap'}), branding.LoginText) : [],
 							dom.label(
 								style({display: 'block', marginBottom: '2ex'}),
 								dom.div('Email address', style({marginBottom: '.5ex'})),
@@ -74,9 +98,35 @@ const login = async (reason: string) => {
 								dom.div('Password', style({marginBottom: '.5ex'})),
 								password=dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required('')),
 							),
+							totpLabel=dom.label(
+								style({display: 'none', marginBottom: '2ex'}),
+								dom.div('Two-factor authentication code, or recovery code', style({marginBottom: '.5ex'})),
+								totp=dom.input(attr.autocomplete('one-time-code')),
+							),
 							dom.div(
 								style({textAlign: 'center'}),
 								dom.submitbutton('Login'),
+								window.PublicKeyCredential ? [
+									' ',
+									dom.clickbutton('Login with passkey', attr.title('Login with a passkey registered for your account, without password. Or, after entering your email address and password, use the passkey as second factor.'), async function click() {
+										reasonElem.remove()
+										try {
+											fieldset.disabled = true
+											const loginToken = await client.LoginPrep()
+											const opts = await client.PasskeyLoginStart(loginToken)
+											const assertion = await passkeyGet(opts)
+											const address = password.value ? username.value : ''
+											const token = await client.PasskeyLogin(loginToken, address, password.value, assertion)
+											loggedIn(token, address)
+										} catch (err) {
+											console.log('passkey login error', err)
+											window.alert('Error: ' + errmsg(err))
+										} finally {
+											fieldset.disabled = false
+										}
+									}),
+								] : [],
+								sso=dom.span(),
 							),
 						),
 					)
@@ -85,9 +135,69 @@ const login = async (reason: string) => {
 		)
 		document.body.appendChild(root)
 		username.focus()
+
+		// Offer login through the identity provider, if configured.
+		client.OIDCEnabled().then(enabled => {
+			if (enabled) {
+				dom._kids(sso, ' ', dom.clickbutton('Login with single sign-on', attr.title('Login through the identity provider of your organization.'), function click() {
+					window.location.href = 'oidc/login'
+				}))
+			}
+		}, err => console.log('checking single sign-on', err))
 	})
 }
 
+// The WebAuthn browser API uses ArrayBuffers, the API base64 strings.
+const base64ToBuffer = (s: string | null | undefined) => Uint8Array.from(window.atob(s || ''), c => c.charCodeAt(0)).buffer
+const bufferToBase64 = (buf: ArrayBuffer) => window.btoa(String.fromCharCode(...new Uint8Array(buf)))
+
+// passkeyGet lets the user select a passkey in the browser, returning the signed
+// response for the login.
+const passkeyGet = async (opts: api.PasskeyGetOptions): Promise<api.PasskeyAssertion> => {
+	const cred = await window.navigator.credentials.get({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rpId: opts.RPID,
+			timeout: opts.Timeout,
+			userVerification: 'preferred',
+		},
+	}) as PublicKeyCredential | null
+	if (!cred) {
+		throw new Error('no passkey selected')
+	}
+	const resp = cred.response as AuthenticatorAssertionResponse
+	return {
+		CredentialID: bufferToBase64(cred.rawId),
+		ClientDataJSON: bufferToBase64(resp.clientDataJSON),
+		AuthenticatorData: bufferToBase64(resp.authenticatorData),
+		Signature: bufferToBase64(resp.signature),
+	}
+}
+
+// passkeyCreate lets the user create a new passkey in the browser or on a
+// security key, returning the client data and attestation object for the
+// registration.
+const passkeyCreate = async (opts: api.PasskeyCreateOptions): Promise<[string, string]> => {
+	const cred = await window.navigator.credentials.create({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rp: {id: opts.RPID, name: opts.RPName},
+			user: {id: base64ToBuffer(opts.UserID), name: opts.UserName, displayName: opts.UserName},
+			pubKeyCredParams: (opts.Algorithms || []).map(alg => ({type: 'public-key', alg: alg})),
+			excludeCredentials: (opts.ExcludeCredentials || []).map(id => ({type: 'public-key', id: base64ToBuffer(id)})),
+			// Passkeys must be discoverable, logins don't specify credentials.
+			authenticatorSelection: {residentKey: 'required', userVerification: 'preferred'},
+			attestation: 'none',
+			timeout: opts.Timeout,
+		},
+	}) as PublicKeyCredential | null
+	if (!cred) {
+		throw new Error('no passkey created')
+	}
+	const resp = cred.response as AuthenticatorAttestationResponse
+	return [bufferToBase64(resp.clientDataJSON), bufferToBase64(resp.attestationObject)]
+}
+
 // Popup shows kids in a centered div with white background on top of a
 // transparent overlay on top of the window. Clicking the overlay or hitting
 // Escape closes the popup. Scrollbars are automatically added to the div with
@@ -300,12 +410,24 @@ const formatQuotaSize = (v: number) => {
 }
 
 const index = async () => {
-	const [[acc, storageUsed, storageLimit, suppressions], tlspubkeys0, recentLoginAttempts] = await Promise.all([
+	const [[acc, storageUsed, storageLimit, suppressions, disabledFeatures], tlspubkeys0, recentLoginAttempts, pgpkeys0, sessions, passwordStatus, twoFactorStatus, passkeys, senderList0] = await Promise.all([
 		client.Account(),
 		client.TLSPublicKeys(),
 		client.LoginAttempts(10),
+		client.PGPKeys(),
+		client.Sessions(),
+		client.PasswordStatus(),
+		client.TwoFactorStatus(),
+		client.Passkeys(),
+		client.SenderList(),
 	])
 	const tlspubkeys = tlspubkeys0 || []
+	const pgpkeys = pgpkeys0 || []
+	const senderList = senderList0 || []
+	const ownedAliases = (acc.OwnedAliases || []).length > 0 ? await client.AliasesOwned() : []
+
+	// Sections for features disabled for the domain are kept, but hidden.
+	const featureHidden = (feature: string) => (disabledFeatures || []).includes(feature) ? style({display: 'none'}) : []
 
 	let fullNameForm: HTMLFormElement
 	let fullNameFieldset: HTMLFieldSetElement
@@ -315,6 +437,7 @@ const index = async () => {
 	let password1: HTMLInputElement
 	let password2: HTMLInputElement
 	let passwordHint: HTMLElement
+	let recoveryCodesBox: HTMLElement
 
 	let autoJunkFlagsFieldset: HTMLFieldSetElement
 	let autoJunkFlagsEnabled: HTMLInputElement
@@ -325,6 +448,9 @@ const index = async () => {
 	let junkFilterFields: HTMLFieldSetElement
 	let junkFilterEnabled: HTMLInputElement
 	let junkThreshold: HTMLInputElement
+	let junkTagThreshold: HTMLInputElement
+	let junkJunkThreshold: HTMLInputElement
+	let junkHighAction: HTMLSelectElement
 	let junkOnegrams: HTMLInputElement
 	let junkTwograms: HTMLInputElement
 	let junkMaxPower: HTMLInputElement
@@ -336,6 +462,37 @@ const index = async () => {
 	let rejectsMailbox: HTMLInputElement
 	let keepRejects: HTMLInputElement
 
+	let screeningFieldset: HTMLFieldSetElement
+	let screeningEnabled: HTMLInputElement
+	let screeningMailbox: HTMLInputElement
+
+	let categoriesFieldset: HTMLFieldSetElement
+	const categoryInputs: {enabled: HTMLInputElement, mailbox: HTMLInputElement, domains: HTMLInputElement}[] = []
+	const categoryRow = (name: string, title: string, cat: api.Category | null | undefined) => {
+		const enabled = dom.input(attr.type('checkbox'), cat ? attr.checked('') : [])
+		const mailbox = dom.input(attr.value(cat?.Mailbox || ''), attr.placeholder('Keyword only'))
+		const domains = dom.input(attr.value((cat?.Domains || []).join(', ')), attr.placeholder('example.org, ...'))
+		categoryInputs.push({enabled: enabled, mailbox: mailbox, domains: domains})
+		return dom.tr(
+			dom.td(dom.label(enabled, ' ', name, attr.title(title))),
+			dom.td(mailbox),
+			dom.td(domains),
+		)
+	}
+	const categoryValue = (i: number): api.Category | null => {
+		const x = categoryInputs[i]
+		if (!x.enabled.checked) {
+			return null
+		}
+		return {
+			Mailbox: x.mailbox.value.trim(),
+			Domains: x.domains.value.split(',').map(s => s.trim()).filter(s => !!s),
+		}
+	}
+
+	let senderListPattern: HTMLInputElement
+	let senderListAction: HTMLSelectElement
+
 	let outgoingWebhookFieldset: HTMLFieldSetElement
 	let outgoingWebhookURL: HTMLInputElement
 	let outgoingWebhookAuthorization: HTMLInputElement
@@ -350,6 +507,7 @@ const index = async () => {
 	let keepRetiredWebhookPeriod: HTMLInputElement
 
 	let fromIDLoginAddressesFieldset: HTMLFieldSetElement
+	let identitiesFieldset: HTMLFieldSetElement
 
 	const second = 1000*1000*1000
 	const minute = 60*second
@@ -561,6 +719,16 @@ const index = async () => {
 			SMTPEnhancedCode: '',
 			Error: '',
 			Extra: {},
+			Recipient: '',
+			Attempts: 0,
+			MaxAttempts: 0,
+			NextAttempt: null,
+			RemoteMTA: '',
+			SMTPResponse: [],
+			TLS: '',
+			MTASTS: false,
+			DANE: false,
+			Transcript: '',
 		}
 		const onchange = function change() {
 			data = {
@@ -577,6 +745,16 @@ const index = async () => {
 				SMTPEnhancedCode: '',
 				Error: error.value,
 				Extra: JSON.parse(extra.value),
+				Recipient: '',
+				Attempts: 0,
+				MaxAttempts: 0,
+				NextAttempt: null,
+				RemoteMTA: '',
+				SMTPResponse: [],
+				TLS: '',
+				MTASTS: false,
+				DANE: false,
+				Transcript: '',
 			}
 			const curlStr = "curl " + (outgoingWebhookAuthorization.value ? "-H 'Authorization: "+outgoingWebhookAuthorization.value+"' " : "") + "-H 'X-Mox-Webhook-ID: 1' -H 'X-Mox-Webhook-Attempt: 1' --json '"+JSON.stringify(data)+"' '"+outgoingWebhookURL.value+"'"
 			dom._kids(curl, style({maxWidth: '45em', wordBreak: 'break-all'}), curlStr)
@@ -750,7 +928,7 @@ const index = async () => {
 	}
 
 	const root = dom.div(
-		crumbs('Mox Account'),
+		crumbs(branding.Name || 'Mox Account'),
 		dom.div(
 			'Default domain: ',
 			acc.DNSDomain.ASCII ? domainString(acc.DNSDomain) : '(none)',
@@ -798,30 +976,232 @@ const index = async () => {
 					dom.th('Subscription address', attr.title('Address subscribed to the alias/list.')),
 					dom.th('Allowed senders', attr.title('Whether only members can send through the alias/list, or anyone.')),
 					dom.th('Send as alias address', attr.title('If enabled, messages can be sent with the alias address in the message "From" header.')),
+					dom.th('Delivery', attr.title('Whether and where messages from the alias/list are delivered for the subscription address.')),
 					dom.th(),
 				),
 			),
-			(acc.Aliases || []).length === 0 ? dom.tr(dom.td(attr.colspan('5'), 'None')) : [],
-			(acc.Aliases || []).sort((a, b) => a.Alias.LocalpartStr < b.Alias.LocalpartStr ? -1 : (domainName(a.Alias.Domain) < domainName(b.Alias.Domain) ? -1 : 1)).map(a =>
-				dom.tr(
-					dom.td(prewrap(a.Alias.LocalpartStr, '@', domainName(a.Alias.Domain))),
+			(acc.Aliases || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [],
+			(acc.Aliases || []).sort((a, b) => a.Alias.LocalpartStr < b.Alias.LocalpartStr ? -1 : (domainName(a.Alias.Domain) < domainName(b.Alias.Domain) ? -1 : 1)).map(a => {
+				const aliasAddress = a.Alias.LocalpartStr + '@' + domainName(a.Alias.Domain)
+				const policy = a.Alias.PostPolicy || (a.Alias.PostPublic ? 'anyone' : 'members')
+				return dom.tr(
+					dom.td(prewrap(aliasAddress)),
 					dom.td(prewrap(a.SubscriptionAddress)),
-					dom.td(a.Alias.PostPublic ? 'Anyone' : 'Members only'),
+					dom.td(policy === 'anyone' ? 'Anyone' : (policy === 'moderated' ? 'Moderated' : 'Members only')),
 					dom.td(a.Alias.AllowMsgFrom ? 'Yes' : 'No'),
 					dom.td(
-						(a.MemberAddresses || []).length === 0 ? [] :
+						a.Member.NoDelivery ? 'None' : (a.Member.Mailbox ? 'Mailbox ' + a.Member.Mailbox : 'Default'), ' ',
+						dom.clickbutton('Change', function click() {
+							let fieldset: HTMLFieldSetElement
+							let noDelivery: HTMLInputElement
+							let mailbox: HTMLInputElement
+							popup(
+								dom.h1('Delivery for alias ', prewrap(aliasAddress)),
+								dom.form(
+									async function submit(e: SubmitEvent) {
+										e.preventDefault()
+										e.stopPropagation()
+										await check(fieldset, client.AliasMemberSave(aliasAddress, a.SubscriptionAddress, {NoDelivery: noDelivery.checked, Mailbox: mailbox.value}))
+										window.location.reload() // todo: reload less
+									},
+									fieldset=dom.fieldset(
+										dom.label(
+											style({display: 'block', marginBottom: '1ex'}),
+											noDelivery=dom.input(attr.type('checkbox'), a.Member.NoDelivery ? attr.checked('') : []),
+											' No delivery',
+											attr.title('Messages sent to the alias/list are not delivered to this address. You can still send messages to the alias/list.'),
+										),
+										dom.label(
+											style({display: 'block', marginBottom: '1ex'}),
+											dom.div('Mailbox', attr.title('Mailbox to deliver messages from the alias/list to. If empty, the mailbox configured for the destination is used.')),
+											mailbox=dom.input(attr.value(a.Member.Mailbox)),
+										),
+										dom.submitbutton('Save'),
+									),
+								),
+							)
+						}),
+					),
+					dom.td(
+						(a.MemberAddresses || []).length === 0 ? [] : [
 							dom.clickbutton('Show members', function click() {
 								popup(
-									dom.h1('Members of alias ', prewrap(a.Alias.LocalpartStr, '@', domainName(a.Alias.Domain))),
+									dom.h1('Members of alias ', prewrap(aliasAddress)),
 									dom.ul(
 										(a.MemberAddresses || []).map(addr => dom.li(prewrap(addr))),
 									),
 								)
 							}),
+							' ',
+						],
+						dom.clickbutton('Leave', attr.title('Remove the subscription address from the members of the alias/list.'), async function click(e: MouseEvent) {
+							if (!window.confirm('Are you sure you want to leave this alias/list?')) {
+								return
+							}
+							await check(e.target! as HTMLButtonElement, client.AliasLeave(aliasAddress, a.SubscriptionAddress))
+							window.location.reload() // todo: reload less
+						}),
+					),
+				)
+			}),
+		),
+		dom.br(),
+
+		ownedAliases.length === 0 ? [] : [
+			dom.h2('Owned aliases/lists', attr.title('Aliases/lists with an address of this account as owner. Owners manage the members. For moderated lists, messages from others than the owners are delivered to the owners only, who can resend approved messages to the list.')),
+			dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Alias address'),
+						dom.th('Allowed senders'),
+						dom.th('Members'),
+						dom.th(),
 					),
 				),
+				ownedAliases.map(o => {
+					let fieldset: HTMLFieldSetElement
+					let addresses: HTMLTextAreaElement
+					return dom.tr(
+						dom.td(prewrap(o.Address)),
+						dom.td(o.PostPolicy === 'anyone' ? 'Anyone' : (o.PostPolicy === 'moderated' ? 'Moderated' : 'Members only')),
+						dom.td(
+							(o.Members || []).map(m =>
+								dom.div(
+									prewrap(m), ' ',
+									dom.clickbutton('Remove', async function click(e: MouseEvent) {
+										await check(e.target! as HTMLButtonElement, client.AliasOwnerMembersRemove(o.Address, [m]))
+										window.location.reload() // todo: reload less
+									}),
+								),
+							),
+						),
+						dom.td(
+							dom.form(
+								async function submit(e: SubmitEvent) {
+									e.preventDefault()
+									e.stopPropagation()
+									await check(fieldset, client.AliasOwnerMembersAdd(o.Address, addresses.value.split('\n').map(s => s.trim()).filter(s => s)))
+									window.location.reload() // todo: reload less
+								},
+								fieldset=dom.fieldset(
+									addresses=dom.textarea(attr.required(''), attr.rows('1'), attr.placeholder('localpart@domain'), attr.title('Addresses of local accounts, one per line.'), function focus() { addresses.setAttribute('rows', '5') }), ' ',
+									dom.submitbutton('Add members', style({verticalAlign: 'top'})),
+								),
+							),
+						),
+					)
+				}),
 			),
-		),
+			dom.br(),
+		],
+
+		dom.h2('Identities', attr.title('Identities for sending messages with webmail, each with a From address, display name and signature. Addresses must be allowed as message From address for the account, e.g. one of the addresses above or an alias that allows sending as the alias address. If no identity is configured, the account addresses are used with the signature from the webmail settings. An HTML signature causes messages sent with the identity to get an HTML version in addition to the plain text.')),
+		(() => {
+			let rows: (() => api.Identity)[] = []
+			let elem: HTMLElement
+
+			const render = () => {
+				rows = []
+
+				const e = dom.form(
+					async function submit(e: SubmitEvent) {
+						e.preventDefault()
+						e.stopPropagation()
+
+						const l = rows.map(fn => fn())
+						await check(identitiesFieldset, client.IdentitiesSave(l))
+						acc.Identities = l
+					},
+					identitiesFieldset=dom.fieldset(
+						dom.table(
+							dom.thead(
+								dom.tr(
+									dom.th('Address'),
+									dom.th('Display name'),
+									dom.th('Signature'),
+									dom.th('HTML signature', attr.title('Optional. If set, messages get an HTML version, with this HTML in place of the text signature.')),
+									dom.th('Reply quoting'),
+									dom.th('Reply-To', attr.title('Optional default Reply-To address for messages composed with this identity.')),
+									dom.th('Default', attr.title('Selected by default when composing new messages.')),
+									dom.th(),
+								),
+							),
+							dom.tbody(
+								(acc.Identities || []).length === 0 ? dom.tr(dom.td(attr.colspan('8'), '(None)')) : [],
+								(acc.Identities || []).map((ident, index) => {
+									let address: HTMLInputElement
+									let displayName: HTMLInputElement
+									let signature: HTMLTextAreaElement
+									let signatureHTML: HTMLInputElement
+									let replyQuoting: HTMLSelectElement
+									let replyTo: HTMLInputElement
+									let isDefault: HTMLInputElement
+									const x = dom.tr(
+										dom.td(address=dom.input(attr.required(''), attr.value(ident.Address))),
+										dom.td(displayName=dom.input(attr.value(ident.DisplayName))),
+										dom.td(signature=dom.textarea(new String((ident.Signature || []).join('\n')), attr.rows('3'))),
+										dom.td(signatureHTML=dom.input(attr.value(ident.SignatureHTML))),
+										dom.td(
+											replyQuoting=dom.select(
+												dom.option(attr.value(''), 'Default'),
+												dom.option(attr.value('bottom'), 'Bottom', ident.ReplyQuoting === 'bottom' ? attr.selected('') : []),
+												dom.option(attr.value('top'), 'Top', ident.ReplyQuoting === 'top' ? attr.selected('') : []),
+											),
+										),
+										dom.td(replyTo=dom.input(attr.value(ident.ReplyTo))),
+										dom.td(isDefault=dom.input(attr.type('checkbox'), ident.Default ? attr.checked('') : [])),
+										dom.td(
+											dom.clickbutton('Remove', function click() {
+												acc.Identities!.splice(index, 1)
+												render()
+											}),
+										),
+									)
+									rows.push(() => {
+										return {
+											Address: address.value,
+											DisplayName: displayName.value,
+											Signature: signature.value ? signature.value.split('\n') : [],
+											SignatureHTML: signatureHTML.value,
+											ReplyQuoting: replyQuoting.value,
+											ReplyTo: replyTo.value,
+											Default: isDefault.checked,
+										}
+									})
+									return x
+								}),
+							),
+							dom.tfoot(
+								dom.tr(
+									dom.td(attr.colspan('7')),
+									dom.td(
+										dom.clickbutton('Add', function click() {
+											const addr = Object.keys(acc.Destinations || {}).filter(s => !s.startsWith('@'))[0] || ''
+											acc.Identities = (acc.Identities || []).concat([{Address: addr, DisplayName: acc.FullName, Signature: [], SignatureHTML: '', ReplyQuoting: '', ReplyTo: '', Default: false}])
+											render()
+										}),
+									),
+								),
+								dom.tr(
+									dom.td(attr.colspan('8'), dom.submitbutton('Save')),
+								),
+							),
+						),
+					),
+				)
+				if (elem) {
+					elem.replaceWith(e)
+					elem = e
+				}
+				return e
+			}
+			elem = render()
+			return elem
+		})(),
+		dom.br(),
+
+		dom.h2('Sessions', attr.title('Logins to the webmail and account web interfaces, and authenticated IMAP and submission connections. If you suspect someone else has access to your account, revoke sessions and change your password.')),
+		renderSessions(sessions || []),
 		dom.br(),
 
 		dom.h2('Recent login attempts', attr.title('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored to prevent unlimited growth of the database.')),
@@ -830,6 +1210,12 @@ const index = async () => {
 		recentLoginAttempts && recentLoginAttempts.length >= 10 ? dom.p('See ', dom.a(attr.href('#loginattempts'), 'all login attempts'), '.') : dom.br(),
 
 		dom.h2('Change password'),
+		passwordStatus.Expired ? box(red, 'Your password has expired. Set a new password to login with other protocols than this account web interface again, e.g. IMAP and SMTP submission.') : [],
+		dom.p(
+			'Password last changed: ', passwordStatus.Changed.getTime() > 0 ? [age(passwordStatus.Changed), ' ago'] : 'unknown',
+			passwordStatus.Expires.getTime() > 0 ? ['. Expires: ', passwordStatus.Expires.toLocaleString()] : [],
+			'.',
+		),
 		acc.NoCustomPassword ?
 			dom.div(
 				dom.clickbutton('Generate and set new password', attr.title('Automatically generate a new password and set it for this account. Custom passwords risk reuse across services and are currently disabled for this account.'), async function click(e: {target: HTMLButtonElement}) {
@@ -893,198 +1279,318 @@ const index = async () => {
 			),
 		dom.br(),
 
-		dom.h2('TLS public keys'),
-		dom.p('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.'),
-		(() => {
-			let elem = dom.div()
+		dom.h2('Recovery codes', attr.title('A recovery code can be used once instead of a two-factor authentication code when logging in to the web interfaces, e.g. if you lost your authenticator app. Your password is still required.')),
+		recoveryCodesBox=dom.div(
+			dom.p('Unused recovery codes: ' + passwordStatus.RecoveryCodes),
+		),
+		dom.clickbutton('Generate new recovery codes', attr.title('Generate new recovery codes, replacing any existing codes.'), async function click(e: {target: HTMLButtonElement}) {
+			if (passwordStatus.RecoveryCodes > 0 && !window.confirm('Are you sure? Existing recovery codes will no longer work.')) {
+				return
+			}
+			const codes = await check(e.target, client.RecoveryCodesGenerate())
+			dom._kids(recoveryCodesBox,
+				dom.p('New recovery codes, each can be used once. Store them securely, they cannot be shown again:'),
+				dom.pre(dom._class('literal'), (codes || []).join('\n')),
+			)
+		}),
+		dom.br(),
 
-			const preauthHelp = 'New IMAP immediate TLS connections authenticated with a client certificate are automatically switched to "authenticated" state with an untagged IMAP "preauth" message by default. IMAP connections have a state machine specifying when commands are allowed. Authenticating is not allowed while in the "authenticated" state. Enable this option to work around clients that would try to authenticated anyway.'
+		dom.h2('Two-factor authentication', attr.title('With two-factor authentication, logins to the web interfaces require a code from an authenticator app on your phone in addition to your password. IMAP/SMTP clients and other protocols cannot use your account password anymore, they must use app passwords.')),
+		renderTwoFactor(twoFactorStatus),
+		dom.br(),
 
-			const render = () => {
-				const e = dom.div(
-					dom.table(
-						dom.thead(
-							dom.tr(
-								dom.th('Login address'),
-								dom.th('Name'),
-								dom.th('Type'),
-			                                        dom.th('No IMAP "preauth"', attr.title(preauthHelp)),
-								dom.th('Fingerprint'),
-								dom.th('Update'),
-								dom.th('Remove'),
+		dom.h2('Passkeys', attr.title('A passkey, stored in your browser, password manager, phone or security key, can be used to login to the web interfaces without password. Passkeys can also be used as second factor after the password, e.g. when two-factor authentication is required.')),
+		renderPasskeys(passkeys || []),
+		dom.br(),
+
+		dom.div(featureHidden('tlspubkeys'),
+			dom.h2('TLS public keys'),
+			dom.p('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.'),
+			(() => {
+				let elem = dom.div()
+
+				const preauthHelp = 'New IMAP immediate TLS connections authenticated with a client certificate are automatically switched to "authenticated" state with an untagged IMAP "preauth" message by default. IMAP connections have a state machine specifying when commands are allowed. Authenticating is not allowed while in the "authenticated" state. Enable this option to work around clients that would try to authenticated anyway.'
+
+				const render = () => {
+					const e = dom.div(
+						dom.table(
+							dom.thead(
+								dom.tr(
+									dom.th('Login address'),
+									dom.th('Name'),
+									dom.th('Type'),
+				                                        dom.th('No IMAP "preauth"', attr.title(preauthHelp)),
+									dom.th('Fingerprint'),
+									dom.th('Update'),
+									dom.th('Remove'),
+								),
 							),
-						),
-						dom.tbody(
-							tlspubkeys.length === 0 ? dom.tr(dom.td(attr.colspan('7'), 'None')) : [],
-							tlspubkeys.map((tpk, index) => {
-								let loginAddress: HTMLInputElement
-								let name: HTMLInputElement
-								let noIMAPPreauth: HTMLInputElement
-								let update: HTMLButtonElement
-
-								const formID = 'tlk-'+index
-								const row = dom.tr(
-									dom.td(
-										dom.form(
-											attr.id(formID),
-											async function submit(e: SubmitEvent) {
-												e.stopPropagation()
-												e.preventDefault()
-
-												const ntpk: api.TLSPublicKey = {...tpk}
-												ntpk.LoginAddress = loginAddress.value
-												ntpk.Name = name.value
-												ntpk.NoIMAPPreauth = noIMAPPreauth.checked
-												await check(update, client.TLSPublicKeyUpdate(ntpk))
-												tpk.LoginAddress = ntpk.LoginAddress
-												tpk.Name = ntpk.Name
-												tpk.NoIMAPPreauth = ntpk.NoIMAPPreauth
-											},
-											loginAddress=dom.input(attr.type('email'), attr.value(tpk.LoginAddress), attr.required('')),
+							dom.tbody(
+								tlspubkeys.length === 0 ? dom.tr(dom.td(attr.colspan('7'), 'None')) : [],
+								tlspubkeys.map((tpk, index) => {
+									let loginAddress: HTMLInputElement
+									let name: HTMLInputElement
+									let noIMAPPreauth: HTMLInputElement
+									let update: HTMLButtonElement
+
+									const formID = 'tlk-'+index
+									const row = dom.tr(
+										dom.td(
+											dom.form(
+												attr.id(formID),
+												async function submit(e: SubmitEvent) {
+													e.stopPropagation()
+													e.preventDefault()
+
+													const ntpk: api.TLSPublicKey = {...tpk}
+													ntpk.LoginAddress = loginAddress.value
+													ntpk.Name = name.value
+													ntpk.NoIMAPPreauth = noIMAPPreauth.checked
+													await check(update, client.TLSPublicKeyUpdate(ntpk))
+													tpk.LoginAddress = ntpk.LoginAddress
+													tpk.Name = ntpk.Name
+													tpk.NoIMAPPreauth = ntpk.NoIMAPPreauth
+												},
+												loginAddress=dom.input(attr.type('email'), attr.value(tpk.LoginAddress), attr.required('')),
+											),
 										),
-									),
-									dom.td(name=dom.input(attr.form(formID), attr.value(tpk.Name), attr.required(''))),
-									dom.td(tpk.Type),
-									dom.td(dom.label(noIMAPPreauth=dom.input(attr.form(formID), attr.type('checkbox'), tpk.NoIMAPPreauth ? attr.checked('') : []), ' No IMAP "preauth"', attr.title(preauthHelp))),
-									dom.td(tpk.Fingerprint),
-									dom.td(update=dom.submitbutton(attr.form(formID), 'Update')),
-									dom.td(
-										dom.form(
-											async function submit(e: SubmitEvent & {target: {disabled: boolean}}) {
-												e.stopPropagation()
-												e.preventDefault()
-												await check(e.target, client.TLSPublicKeyRemove(tpk.Fingerprint))
-												tlspubkeys.splice(tlspubkeys.indexOf(tpk), 1)
-												render()
-											},
-											dom.submitbutton('Remove'),
+										dom.td(name=dom.input(attr.form(formID), attr.value(tpk.Name), attr.required(''))),
+										dom.td(tpk.Type),
+										dom.td(dom.label(noIMAPPreauth=dom.input(attr.form(formID), attr.type('checkbox'), tpk.NoIMAPPreauth ? attr.checked('') : []), ' No IMAP "preauth"', attr.title(preauthHelp))),
+										dom.td(tpk.Fingerprint),
+										dom.td(update=dom.submitbutton(attr.form(formID), 'Update')),
+										dom.td(
+											dom.form(
+												async function submit(e: SubmitEvent & {target: {disabled: boolean}}) {
+													e.stopPropagation()
+													e.preventDefault()
+													await check(e.target, client.TLSPublicKeyRemove(tpk.Fingerprint))
+													tlspubkeys.splice(tlspubkeys.indexOf(tpk), 1)
+													render()
+												},
+												dom.submitbutton('Remove'),
+											),
 										),
-									),
-								)
-								return row
-							}),
+									)
+									return row
+								}),
+							),
 						),
-					),
-					dom.clickbutton('Add', style({marginTop: '1ex'}), function click() {
-						let address: HTMLInputElement
-						let name: HTMLInputElement
-						let noIMAPPreauth: HTMLInputElement
-						let file: HTMLInputElement
-
-						const close = popup(
-							dom.div(
-								style({maxWidth: '45em'}),
-								dom.h1('Add TLS public key'),
-								dom.form(
-									async function submit(e: SubmitEvent & {target: {disabled: boolean}}) {
-										e.preventDefault()
-										e.stopPropagation()
-										if (file.files?.length !== 1) {
-											throw new Error('exactly 1 certificate required') // xxx
-										}
-										const certPEM = await new Promise<string>((resolve, reject) => {
-											const fr = new window.FileReader()
-											fr.addEventListener('load', () => {
-												resolve(fr.result as string)
-											})
-											fr.addEventListener('error', () => {
-												reject(fr.error)
+						dom.clickbutton('Add', style({marginTop: '1ex'}), function click() {
+							let address: HTMLInputElement
+							let name: HTMLInputElement
+							let noIMAPPreauth: HTMLInputElement
+							let file: HTMLInputElement
+
+							const close = popup(
+								dom.div(
+									style({maxWidth: '45em'}),
+									dom.h1('Add TLS public key'),
+									dom.form(
+										async function submit(e: SubmitEvent & {target: {disabled: boolean}}) {
+											e.preventDefault()
+											e.stopPropagation()
+											if (file.files?.length !== 1) {
+												throw new Error('exactly 1 certificate required') // xxx
+											}
+											const certPEM = await new Promise<string>((resolve, reject) => {
+												const fr = new window.FileReader()
+												fr.addEventListener('load', () => {
+													resolve(fr.result as string)
+												})
+												fr.addEventListener('error', () => {
+													reject(fr.error)
+												})
+												fr.readAsText(file.files![0])
 											})
-											fr.readAsText(file.files![0])
-										})
-										const ntpk = await check(e.target, client.TLSPublicKeyAdd(address.value, name.value, noIMAPPreauth.checked, certPEM))
-										tlspubkeys.push(ntpk)
-										render()
-										close()
-									},
-									dom.label(
-										style({display: 'block', marginBottom: '1ex'}),
-										dom.div(dom.b('Login address')),
-										address=dom.input(attr.type('email'), attr.value(localStorageGet('webaccountaddress') || ''), attr.required('')),
-										dom.div(style({fontStyle: 'italic', marginTop: '.5ex'}), 'Login address used for sessions using this key.'),
-									),
-									dom.label(
-										style({display: 'block', marginBottom: '1ex'}),
-										noIMAPPreauth=dom.input(attr.type('checkbox')),
-										' No IMAP "preauth"',
-										attr.title(preauthHelp),
-									),
-									dom.div(
-										style({display: 'block', marginBottom: '1ex'}),
+											const ntpk = await check(e.target, client.TLSPublicKeyAdd(address.value, name.value, noIMAPPreauth.checked, certPEM))
+											tlspubkeys.push(ntpk)
+											render()
+											close()
+										},
 										dom.label(
-											dom.div(dom.b('Certificate')),
-											file=dom.input(attr.type('file'), attr.required('')),
+											style({display: 'block', marginBottom: '1ex'}),
+											dom.div(dom.b('Login address')),
+											address=dom.input(attr.type('email'), attr.value(localStorageGet('webaccountaddress') || ''), attr.required('')),
+											dom.div(style({fontStyle: 'italic', marginTop: '.5ex'}), 'Login address used for sessions using this key.'),
 										),
-										dom.p(
-											style({fontStyle: 'italic', margin: '1ex 0'}),
-											'Upload a PEM file containing a certificate, not a private key. Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration, and constraints are not verified. ',
-											dom.a('Show suggested commands', attr.href(''), function click(e: MouseEvent) {
-												e.preventDefault()
-												popup(
-													dom.h1('Generate a private key and certificate'),
-													dom.pre(
-														dom._class('literal'),
-`export keyname=...    # Used for file names, certificate "common name" and as name of tls public key.
-                      # Suggestion: Use an application name and/or email address.
-export passphrase=... # Protects the private key in the PEM and p12 files.
-
-# Generate an ECDSA P-256 private key and a long-lived, unsigned, basic certificate
-# for the corresponding public key.
-openssl req \\
-	-config /dev/null \\
-	-x509 \\
-	-newkey ec \\
-	-pkeyopt ec_paramgen_curve:P-256 \\
-	-passout env:passphrase \\
-	-keyout "$keyname.ecdsa-p256.privatekey.pkcs8.pem" \\
-	-out "$keyname.ecdsa-p256.certificate.pem" \\
-	-days 36500 \\
-	-subj "/CN=$keyname"
-
-# Generate a p12 file containing both certificate and private key, for
-# applications/operating systems that cannot read PEM files with
-# certificates/private keys.
-openssl pkcs12 \\
-	-export \\
-	-in "$keyname.ecdsa-p256.certificate.pem" \\
-	-inkey "$keyname.ecdsa-p256.privatekey.pkcs8.pem" \\
-	-name "$keyname" \\
-	-passin env:passphrase \\
-	-passout env:passphrase \\
-	-out "$keyname.ecdsa-p256-privatekey-certificate.p12"
-
-# If the p12 file cannot be imported in the destination OS or email application,
-# try adding -legacy to the "openssl pkcs12" command.
-`
-													),
-												)
-											}),
-											' for generating a private key and certificate.',
+										dom.label(
+											style({display: 'block', marginBottom: '1ex'}),
+											noIMAPPreauth=dom.input(attr.type('checkbox')),
+											' No IMAP "preauth"',
+											attr.title(preauthHelp),
 										),
+										dom.div(
+											style({display: 'block', marginBottom: '1ex'}),
+											dom.label(
+												dom.div(dom.b('Certificate')),
+												file=dom.input(attr.type('file'), attr.required('')),
+											),
+											dom.p(
+												style({fontStyle: 'italic', margin: '1ex 0'}),
+												'Upload a PEM file containing a certificate, not a private key. Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration, and constraints are not verified. ',
+												dom.a('Show suggested commands', attr.href(''), function click(e: MouseEvent) {
+													e.preventDefault()
+													popup(
+														dom.h1('Generate a private key and certificate'),
+														dom.pre(
+															dom._class('literal'),
+	`export keyname=...    # Used for file names, certificate "common name" and as name of tls public key.
+	                      # Suggestion: Use an application name and/or email address.
+	export passphrase=... # Protects the private key in the PEM and p12 files.
+
+	# Generate an ECDSA P-256 private key and a long-lived, unsigned, basic certificate
+	# for the corresponding public key.
+	openssl req \\
+		-config /dev/null \\
+		-x509 \\
+		-newkey ec \\
+		-pkeyopt ec_paramgen_curve:P-256 \\
+		-passout env:passphrase \\
+		-keyout "$keyname.ecdsa-p256.privatekey.pkcs8.pem" \\
+		-out "$keyname.ecdsa-p256.certificate.pem" \\
+		-days 36500 \\
+		-subj "/CN=$keyname"
+
+	# Generate a p12 file containing both certificate and private key, for
+	# applications/operating systems that cannot read PEM files with
+	# certificates/private keys.
+	openssl pkcs12 \\
+		-export \\
+		-in "$keyname.ecdsa-p256.certificate.pem" \\
+		-inkey "$keyname.ecdsa-p256.privatekey.pkcs8.pem" \\
+		-name "$keyname" \\
+		-passin env:passphrase \\
+		-passout env:passphrase \\
+		-out "$keyname.ecdsa-p256-privatekey-certificate.p12"
+
+	# If the p12 file cannot be imported in the destination OS or email application,
+	# try adding -legacy to the "openssl pkcs12" command.
+	`
+														),
+													)
+												}),
+												' for generating a private key and certificate.',
+											),
+										),
+										dom.label(
+											style({display: 'block', marginBottom: '1ex'}),
+											dom.div(dom.b('Name')),
+											name=dom.input(),
+											dom.div(style({fontStyle: 'italic', marginTop: '.5ex'}), 'Optional. If empty, the "subject common name" from the certificate is used.'),
+										),
+										dom.br(),
+										dom.submitbutton('Add'),
 									),
-									dom.label(
-										style({display: 'block', marginBottom: '1ex'}),
-										dom.div(dom.b('Name')),
-										name=dom.input(),
-										dom.div(style({fontStyle: 'italic', marginTop: '.5ex'}), 'Optional. If empty, the "subject common name" from the certificate is used.'),
-									),
-									dom.br(),
-									dom.submitbutton('Add'),
+								),
+							)
+						})
+					)
+
+					if (elem) {
+						elem.replaceWith(e)
+					}
+					elem = e
+				}
+				render()
+				return elem
+			})(),
+			dom.br(),
+		),
+
+		dom.div(featureHidden('pgp'),
+			dom.h2('OpenPGP keys'),
+			dom.p('Keys for signing, encrypting, verifying and decrypting messages in the webmail. Add public keys of correspondents to verify their signatures and encrypt messages to them. Add your own key including private key to sign messages and decrypt messages sent to you. Keys with a private key can be published in the Web Key Directory of your domain, where other mail clients look up keys for encrypting messages to you.'),
+			(() => {
+				let elem = dom.div()
+
+				const render = () => {
+					const e = dom.div(
+						dom.table(
+							dom.thead(
+								dom.tr(
+									dom.th('Key ID'),
+									dom.th('Addresses'),
+									dom.th('Created'),
+									dom.th('Private key'),
+									dom.th('Publish', attr.title('Publish the public key in the Web Key Directory of the domain of the address.')),
+									dom.th('Remove'),
 								),
 							),
-						)
-					})
-				)
+							dom.tbody(
+								pgpkeys.length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [],
+								pgpkeys.map(k =>
+									dom.tr(
+										dom.td(k.KeyID, attr.title('Fingerprint: '+k.Fingerprint)),
+										dom.td((k.Addresses || []).join(', ')),
+										dom.td(k.Created.toISOString().split('T')[0]),
+										dom.td(k.Private ? 'Yes' : 'No'),
+										dom.td(
+											k.Private ?
+												dom.input(attr.type('checkbox'), k.Publish ? attr.checked('') : [], async function change(e: Event) {
+													const elem = e.target! as HTMLInputElement
+													await check(elem, client.PGPKeyPublish(k.Fingerprint, elem.checked))
+													k.Publish = elem.checked
+												}) : '-',
+										),
+										dom.td(
+											dom.clickbutton('Remove', async function click(e: MouseEvent) {
+												if (!window.confirm('Are you sure you want to remove this key?')) {
+													return
+												}
+												await check(e.target! as HTMLButtonElement, client.PGPKeyRemove(k.Fingerprint))
+												pgpkeys.splice(pgpkeys.indexOf(k), 1)
+												render()
+											}),
+										),
+									)
+								),
+							),
+						),
+						dom.clickbutton('Add', style({marginTop: '1ex'}), function click() {
+							let armored: HTMLTextAreaElement
+
+							const close = popup(
+								dom.div(
+									style({maxWidth: '45em'}),
+									dom.h1('Add OpenPGP key'),
+									dom.form(
+										async function submit(e: SubmitEvent & {target: {disabled: boolean}}) {
+											e.preventDefault()
+											e.stopPropagation()
+											const nk = await check(e.target, client.PGPKeyAdd(armored.value))
+											const i = pgpkeys.findIndex(k => k.Fingerprint === nk.Fingerprint)
+											if (i >= 0) {
+												pgpkeys[i] = nk
+											} else {
+												pgpkeys.push(nk)
+											}
+											render()
+											close()
+										},
+										dom.label(
+											style({display: 'block', marginBottom: '1ex'}),
+											dom.div(dom.b('Armored key')),
+											armored=dom.textarea(attr.required(''), attr.rows('12'), style({width: '100%', fontFamily: 'monospace'})),
+											dom.div(style({fontStyle: 'italic', marginTop: '.5ex'}), 'A public key, or a private key, e.g. exported with "gpg --armor --export-secret-keys". Private keys are stored as given, typically protected with a passphrase, which is needed when signing or decrypting. Adding the private key for an existing public key adds it to the key.'),
+										),
+										dom.submitbutton('Add'),
+									),
+								),
+							)
+						}),
+					)
 
-				if (elem) {
-					elem.replaceWith(e)
+					if (elem) {
+						elem.replaceWith(e)
+					}
+					elem = e
 				}
-				elem = e
-			}
-			render()
-			return elem
-		})(),
-		dom.br(),
+				render()
+				return elem
+			})(),
+			dom.br(),
+		),
 
 		dom.h2('Disk usage'),
 		dom.p('Storage used is ', dom.b(formatQuotaSize(Math.floor(storageUsed/(1024*1024))*1024*1024)),
@@ -1140,6 +1646,9 @@ openssl pkcs12 \\
 					}
 					const r: api.JunkFilter = {
 						Threshold: parseFloat(junkThreshold.value),
+						TagThreshold: parseFloat(junkTagThreshold.value) || 0,
+						JunkThreshold: parseFloat(junkJunkThreshold.value) || 0,
+						HighAction: junkHighAction.value,
 						Onegrams: junkOnegrams.checked,
 						Twograms: junkTwograms.checked,
 						Threegrams: acc.JunkFilter?.Threegrams || false, // Ignored on server.
@@ -1164,6 +1673,27 @@ openssl pkcs12 \\
 						attr.title('Approximate spaminess score between 0 and 1 above which emails are rejected as spam. Each delivery attempt adds a little noise to make it slightly harder for spammers to identify words that strongly indicate non-spaminess and use it to bypass the filter. E.g. 0.95.'),
 						dom.div(junkThreshold=dom.input(attr.value(''+(acc.JunkFilter?.Threshold || '0.95')))),
 					),
+					dom.label(
+						'Tag threshold',
+						attr.title('Spaminess score between 0 and 1 above which accepted messages get X-Mox-Spam-Flag and X-Mox-Spam-Score headers, e.g. for filtering in mail clients. E.g. 0.5. Empty for the default of the domain of the account.'),
+						dom.div(junkTagThreshold=dom.input(attr.value(acc.JunkFilter?.TagThreshold ? ''+acc.JunkFilter.TagThreshold : ''))),
+					),
+					dom.label(
+						'Junk threshold',
+						attr.title('Spaminess score between 0 and 1 above which messages are accepted but delivered to the Junk mailbox. Should be below Threshold. E.g. 0.8. Empty for the default of the domain of the account.'),
+						dom.div(junkJunkThreshold=dom.input(attr.value(acc.JunkFilter?.JunkThreshold ? ''+acc.JunkFilter.JunkThreshold : ''))),
+					),
+					dom.label(
+						'Above threshold',
+						attr.title('Action for messages with a spaminess score above Threshold. Reject rejects the message during the SMTP transaction. Quarantine accepts the message, but only stores it in the rejects mailbox, which must be configured below.'),
+						dom.div(
+							junkHighAction=dom.select(
+								dom.option('Default', attr.value('')),
+								dom.option('Reject', attr.value('reject'), acc.JunkFilter?.HighAction === 'reject' ? attr.selected('') : []),
+								dom.option('Quarantine', attr.value('quarantine'), acc.JunkFilter?.HighAction === 'quarantine' ? attr.selected('') : []),
+							),
+						),
+					),
 					dom.label(
 						'Onegrams',
 						attr.title('Track ham/spam ranking for single words.'),
@@ -1231,108 +1761,227 @@ openssl pkcs12 \\
 		),
 		dom.br(),
 
-		dom.h2('Webhooks'),
-		dom.h3('Outgoing', attr.title('Webhooks for outgoing messages are called for each attempt to deliver a message in the outgoing queue, e.g. when the queue has delivered a message to the next hop, when a single attempt failed with a temporary error, when delivery permanently failed, or when DSN (delivery status notification) messages were received about a previously sent message.')),
+		dom.h2('Screening', attr.title('With screening, messages from senders you have not exchanged messages with before are delivered to a screening mailbox instead of the Inbox. Moving a message out of the screening mailbox, to a mailbox other than Junk or Trash, approves the sender by adding it to the sender allow list below. Messages matched by rulesets, forwarded messages and mailing list messages are not screened.')),
 		dom.form(
 			async function submit(e: SubmitEvent) {
 				e.preventDefault()
 				e.stopPropagation()
 
-				await check(outgoingWebhookFieldset, client.OutgoingWebhookSave(outgoingWebhookURL.value, outgoingWebhookAuthorization.value, [...outgoingWebhookEvents.selectedOptions].map(o => o.value)))
+				await check(screeningFieldset, client.ScreeningSave(screeningEnabled.checked ? {Mailbox: screeningMailbox.value} : null))
 			},
-			outgoingWebhookFieldset=dom.fieldset(
+			screeningFieldset=dom.fieldset(
 				dom.div(style({display: 'flex', gap: '1em'}),
-					dom.div(
-						dom.label(
-							dom.div('URL', attr.title('URL to do an HTTP POST to for each event. Webhooks are disabled if empty.')),
-							outgoingWebhookURL=dom.input(attr.value(acc.OutgoingWebhook?.URL || ''), style({width: '30em'})),
-						),
-					),
-					dom.div(
-						dom.label(
-							dom.div(
-								'Authorization header ',
-								dom.a(
-									'Basic',
-									attr.href(''),
-									function click(e: MouseEvent) {
-										e.preventDefault()
-										authorizationPopup(outgoingWebhookAuthorization)
-									},
-								),
-								attr.title('If non-empty, HTTP requests have this value as Authorization header, e.g. Basic <base64-encoded-username-password>.'),
-							),
-							outgoingWebhookAuthorization=dom.input(attr.value(acc.OutgoingWebhook?.Authorization || '')),
-						),
-					),
-					dom.div(
-						dom.label(
-							style({verticalAlign: 'top'}),
-							dom.div('Events', attr.title('Either limit to specific events, or receive all events (default).')),
-							outgoingWebhookEvents=dom.select(
-								style({verticalAlign: 'bottom'}),
-								attr.multiple(''),
-								attr.size('8'), // Number of options.
-								["delivered", "suppressed", "delayed", "failed", "relayed", "expanded", "canceled", "unrecognized"].map(s => dom.option(s.substring(0, 1).toUpperCase()+s.substring(1), attr.value(s), acc.OutgoingWebhook?.Events?.includes(s) ? attr.selected('') : [])),
-							),
-						),
+					dom.label(
+						'Enabled',
+						dom.div(screeningEnabled=dom.input(attr.type('checkbox'), acc.Screening ? attr.checked('') : [])),
 					),
-					dom.div(
-						dom.div(dom.label('\u00a0')),
-						dom.submitbutton('Save'), ' ',
-						dom.clickbutton('Test', function click() {
-							popupTestOutgoing()
-						}),
+					dom.label(
+						'Mailbox',
+						attr.title('Mailbox for messages from first-time senders awaiting approval, created when needed. Default: Screening.'),
+						dom.div(screeningMailbox=dom.input(attr.value(acc.Screening?.Mailbox || ''), attr.placeholder('Screening'))),
 					),
+					dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))),
 				),
 			),
 		),
 		dom.br(),
-		dom.h3('Incoming', attr.title('Webhooks for incoming messages are called for each message received over SMTP, excluding DSN messages about previous deliveries.')),
+		dom.h2('Categories', attr.title('Incoming bulk messages that are not spam can be categorized as newsletters, notifications or updates from social networks, and delivered to a separate mailbox, or to the usual mailbox with a keyword ($newsletter, $notification or $social). Categorization does not influence junk filtering. Messages matched by rulesets, and messages delivered to the screening mailbox, are not categorized. Messages from verified sender domains configured for a category are always in that category.')),
 		dom.form(
 			async function submit(e: SubmitEvent) {
 				e.preventDefault()
 				e.stopPropagation()
 
-				await check(incomingWebhookFieldset, client.IncomingWebhookSave(incomingWebhookURL.value, incomingWebhookAuthorization.value))
+				const categories: api.Categories = {
+					Newsletters: categoryValue(0),
+					Notifications: categoryValue(1),
+					Social: categoryValue(2),
+				}
+				const enabled = categories.Newsletters || categories.Notifications || categories.Social
+				await check(categoriesFieldset, client.CategoriesSave(enabled ? categories : null))
 			},
-			incomingWebhookFieldset=dom.fieldset(
-				dom.div(
-					style({display: 'flex', gap: '1em'}),
-					dom.div(
-						dom.label(
-							dom.div('URL'),
-							incomingWebhookURL=dom.input(attr.value(acc.IncomingWebhook?.URL || ''), style({width: '30em'})),
+			categoriesFieldset=dom.fieldset(
+				dom.table(
+					dom.thead(
+						dom.tr(
+							dom.th('Category'),
+							dom.th('Mailbox', attr.title('Mailbox to deliver messages in this category to, created when needed. If empty, messages are delivered to the usual mailbox, with only the keyword set.')),
+							dom.th('Sender domains', attr.title('Comma-separated sender domains whose messages are always in this category, including subdomains, if verified through the DMARC-aligned message From address or a DKIM signature. E.g. github.com for notifications.')),
 						),
 					),
-					dom.div(
-						dom.label(
-							dom.div(
-								'Authorization header ',
-								dom.a(
-									'Basic',
-									attr.href(''),
-									function click(e: MouseEvent) {
-										e.preventDefault()
-										authorizationPopup(incomingWebhookAuthorization)
-									},
+					dom.tbody(
+						categoryRow('Newsletters', 'Newsletters, marketing and mailing list messages, recognized by mailing list headers (List-Id, List-Unsubscribe) or bulk precedence.', acc.Categories?.Newsletters),
+						categoryRow('Notifications', 'Automated messages like receipts and alerts, recognized by an Auto-Submitted header or a sender like noreply@.', acc.Categories?.Notifications),
+						categoryRow('Social', 'Updates from social networks, recognized by a well-known social network sender domain, or the configured sender domains.', acc.Categories?.Social),
+					),
+				),
+				dom.br(),
+				dom.submitbutton('Save'),
+			),
+		),
+		dom.br(),
+		dom.h2('Sender lists', attr.title('Block or allow messages from senders, by address (user@example.org), domain (example.org), or domain including subdomains (*.example.org). Messages from rejected senders are refused during the SMTP transaction. Messages from discarded senders are accepted but not delivered. Messages from allowed senders bypass junk filtering, but only if the message From address is verified with SPF/DKIM/DMARC. Rejects and discards are matched against both the message From address and the SMTP MAIL FROM address. The most specific entry applies.')),
+		dom.form(
+			attr.id('senderListAdd'),
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+
+				await check(e.target! as HTMLButtonElement, client.SenderListAdd(senderListPattern.value, senderListAction.value as api.SenderListAction))
+				window.location.reload() // todo: reload less
+			},
+		),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Address or domain'),
+					dom.th('Action'),
+					dom.th('Since'),
+					dom.th(),
+				),
+			),
+			dom.tbody(
+				senderList.length === 0 ? dom.tr(dom.td(attr.colspan('4'), '(None)')) : [],
+				senderList.map(e =>
+					dom.tr(
+						dom.td(e.Pattern),
+						dom.td(
+							dom.select(
+								async function change(ev: Event) {
+									const sel = ev.target! as HTMLSelectElement
+									await check(sel, client.SenderListAdd(e.Pattern, sel.value as api.SenderListAction))
+								},
+								Object.values(api.SenderListAction).map(a => dom.option(a, attr.value(a), a === e.Action ? attr.selected('') : [])),
+							),
+						),
+						dom.td(age(e.Created)),
+						dom.td(
+							dom.clickbutton('Remove', async function click(ev: MouseEvent) {
+								await check(ev.target! as HTMLButtonElement, client.SenderListRemove(e.ID))
+								window.location.reload() // todo: reload less
+							}),
+						),
+					),
+				),
+			),
+			dom.tfoot(
+				dom.tr(
+					dom.td(senderListPattern=dom.input(attr.required(''), attr.placeholder('user@example.org, example.org or *.example.org'), attr.form('senderListAdd'))),
+					dom.td(
+						senderListAction=dom.select(attr.form('senderListAdd'),
+							Object.values(api.SenderListAction).map(a => dom.option(a, attr.value(a), a === api.SenderListAction.SenderListReject ? attr.selected('') : [])),
+						),
+					),
+					dom.td(),
+					dom.td(dom.submitbutton('Add', attr.form('senderListAdd'))),
+				),
+			),
+		),
+		dom.br(),
+
+		dom.div(featureHidden('webhooks'),
+			dom.h2('Webhooks'),
+			dom.h3('Outgoing', attr.title('Webhooks for outgoing messages are called for each attempt to deliver a message in the outgoing queue, e.g. when the queue has delivered a message to the next hop, when a single attempt failed with a temporary error, when delivery permanently failed, or when DSN (delivery status notification) messages were received about a previously sent message.')),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+
+					await check(outgoingWebhookFieldset, client.OutgoingWebhookSave(outgoingWebhookURL.value, outgoingWebhookAuthorization.value, [...outgoingWebhookEvents.selectedOptions].map(o => o.value)))
+				},
+				outgoingWebhookFieldset=dom.fieldset(
+					dom.div(style({display: 'flex', gap: '1em'}),
+						dom.div(
+							dom.label(
+								dom.div('URL', attr.title('URL to do an HTTP POST to for each event. Webhooks are disabled if empty.')),
+								outgoingWebhookURL=dom.input(attr.value(acc.OutgoingWebhook?.URL || ''), style({width: '30em'})),
+							),
+						),
+						dom.div(
+							dom.label(
+								dom.div(
+									'Authorization header ',
+									dom.a(
+										'Basic',
+										attr.href(''),
+										function click(e: MouseEvent) {
+											e.preventDefault()
+											authorizationPopup(outgoingWebhookAuthorization)
+										},
+									),
+									attr.title('If non-empty, HTTP requests have this value as Authorization header, e.g. Basic <base64-encoded-username-password>.'),
 								),
-								attr.title('If non-empty, HTTP requests have this value as Authorization header, e.g. Basic <base64-encoded-username-password>.'),
+								outgoingWebhookAuthorization=dom.input(attr.value(acc.OutgoingWebhook?.Authorization || '')),
 							),
-							incomingWebhookAuthorization=dom.input(attr.value(acc.IncomingWebhook?.Authorization || '')),
+						),
+						dom.div(
+							dom.label(
+								style({verticalAlign: 'top'}),
+								dom.div('Events', attr.title('Either limit to specific events, or receive all events (default).')),
+								outgoingWebhookEvents=dom.select(
+									style({verticalAlign: 'bottom'}),
+									attr.multiple(''),
+									attr.size('8'), // Number of options.
+									["delivered", "suppressed", "delayed", "failed", "relayed", "expanded", "canceled", "unrecognized"].map(s => dom.option(s.substring(0, 1).toUpperCase()+s.substring(1), attr.value(s), acc.OutgoingWebhook?.Events?.includes(s) ? attr.selected('') : [])),
+								),
+							),
+						),
+						dom.div(
+							dom.div(dom.label('\u00a0')),
+							dom.submitbutton('Save'), ' ',
+							dom.clickbutton('Test', function click() {
+								popupTestOutgoing()
+							}),
 						),
 					),
+				),
+			),
+			dom.br(),
+			dom.h3('Incoming', attr.title('Webhooks for incoming messages are called for each message received over SMTP, excluding DSN messages about previous deliveries.')),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+
+					await check(incomingWebhookFieldset, client.IncomingWebhookSave(incomingWebhookURL.value, incomingWebhookAuthorization.value))
+				},
+				incomingWebhookFieldset=dom.fieldset(
 					dom.div(
-						dom.div(dom.label('\u00a0')),
-						dom.submitbutton('Save'), ' ',
-						dom.clickbutton('Test', function click() {
-							popupTestIncoming()
-						}),
+						style({display: 'flex', gap: '1em'}),
+						dom.div(
+							dom.label(
+								dom.div('URL'),
+								incomingWebhookURL=dom.input(attr.value(acc.IncomingWebhook?.URL || ''), style({width: '30em'})),
+							),
+						),
+						dom.div(
+							dom.label(
+								dom.div(
+									'Authorization header ',
+									dom.a(
+										'Basic',
+										attr.href(''),
+										function click(e: MouseEvent) {
+											e.preventDefault()
+											authorizationPopup(incomingWebhookAuthorization)
+										},
+									),
+									attr.title('If non-empty, HTTP requests have this value as Authorization header, e.g. Basic <base64-encoded-username-password>.'),
+								),
+								incomingWebhookAuthorization=dom.input(attr.value(acc.IncomingWebhook?.Authorization || '')),
+							),
+						),
+						dom.div(
+							dom.div(dom.label('\u00a0')),
+							dom.submitbutton('Save'), ' ',
+							dom.clickbutton('Test', function click() {
+								popupTestIncoming()
+							}),
+						),
 					),
 				),
 			),
+			dom.br(),
 		),
-		dom.br(),
 
 		dom.h2('Keep messages/webhooks retired from queue', attr.title('After delivering a message or webhook from the queue it is removed by default. But you can also keep these "retired" messages/webhooks around for a while. With unique SMTP MAIL FROM addresses configured below, this allows relating incoming delivery status notification messages (DSNs) to previously sent messages and their original recipients, which is needed for automatic management of recipient suppression lists, which is important for managing the reputation of your mail server. For both messages and webhooks, this can be useful for debugging. Use values like "3d" for 3 days, or units "s" for second, "m" for minute, "h" for hour, "w" for week.')),
 		dom.form(
@@ -1446,17 +2095,19 @@ openssl pkcs12 \\
 				dom.tr(
 					dom.th('Address', attr.title('Address that caused this entry to be added to the list. The title (shown on hover) displays an address with a fictional simplified localpart, with lower-cased, dots removed, only first part before "+" or "-" (typicaly catchall separators). When checking if an address is on the suppression list, it is checked against this address.')),
 					dom.th('Manual', attr.title('Whether suppression was added manually, instead of automatically based on bounces.')),
+					dom.th('Class', attr.title('For automatically added suppressions, the classification of the delivery failure: hard, soft, block or policy.')),
 					dom.th('Reason'),
 					dom.th('Since'),
 					dom.th('Action'),
 				),
 			),
 			dom.tbody(
-				(suppressions || []).length === 0 ? dom.tr(dom.td(attr.colspan('5'), '(None)')) : [],
+				(suppressions || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), '(None)')) : [],
 				(suppressions || []).map(s =>
 					dom.tr(
 						dom.td(prewrap(s.OriginalAddress), attr.title(s.BaseAddress)),
 						dom.td(s.Manual ? '✓' : ''),
+						dom.td(s.BounceClass),
 						dom.td(s.Reason),
 						dom.td(age(s.Created)),
 						dom.td(
@@ -1472,6 +2123,7 @@ openssl pkcs12 \\
 				dom.tr(
 					dom.td(suppressionAddress=dom.input(attr.type('required'), attr.form('suppressionAdd'))),
 					dom.td(),
+					dom.td(),
 					dom.td(suppressionReason=dom.input(style({width: '100%'}), attr.form('suppressionAdd'))),
 					dom.td(),
 					dom.td(dom.submitbutton('Add suppression', attr.form('suppressionAdd'))),
@@ -1480,129 +2132,134 @@ openssl pkcs12 \\
 		),
 		dom.br(),
 
-		dom.h2('Export'),
-		dom.p('Export all messages in all mailboxes.'),
-		dom.form(
-			attr.target('_blank'), attr.method('POST'), attr.action('export'),
-			dom.input(attr.type('hidden'), attr.name('csrf'), attr.value(localStorageGet('webaccountcsrftoken') || '')),
-			dom.input(attr.type('hidden'), attr.name('mailbox'), attr.value('')),
-			dom.input(attr.type('hidden'), attr.name('recursive'), attr.value('on')),
+		dom.div(featureHidden('export'),
+			dom.h2('Export'),
+			dom.p('Export all messages in all mailboxes.'),
+			dom.form(
+				attr.target('_blank'), attr.method('POST'), attr.action('export'),
+				dom.input(attr.type('hidden'), attr.name('csrf'), attr.value(localStorageGet('webaccountcsrftoken') || '')),
+				dom.input(attr.type('hidden'), attr.name('mailbox'), attr.value('')),
+				dom.input(attr.type('hidden'), attr.name('recursive'), attr.value('on')),
 
-			dom.div(style({display: 'flex', flexDirection: 'column', gap: '.5ex'}),
-				dom.div(
-					dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('maildir'), attr.checked('')), ' Maildir'), ' ',
-					dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('mbox')), ' Mbox'),
-				),
-				dom.div(
-					dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tar')), ' Tar'), ' ',
-					dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tgz'), attr.checked('')), ' Tgz'), ' ',
-					dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('zip')), ' Zip'), ' ',
+				dom.div(style({display: 'flex', flexDirection: 'column', gap: '.5ex'}),
+					dom.div(
+						dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('maildir'), attr.checked('')), ' Maildir'), ' ',
+						dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('maildir++')), ' Maildir++ (Dovecot)', attr.title('Maildir++ layout with dovecot-uidlist and subscriptions files, for use as Dovecot mail store.')), ' ',
+						dom.label(dom.input(attr.type('radio'), attr.name('format'), attr.value('mbox')), ' Mbox'),
+					),
+					dom.div(
+						dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tar')), ' Tar'), ' ',
+						dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('tgz'), attr.checked('')), ' Tgz'), ' ',
+						dom.label(dom.input(attr.type('radio'), attr.name('archive'), attr.value('zip')), ' Zip'), ' ',
+					),
+					dom.div(style({marginTop: '1ex'}), dom.submitbutton('Export')),
 				),
-				dom.div(style({marginTop: '1ex'}), dom.submitbutton('Export')),
 			),
+			dom.br(),
 		),
-		dom.br(),
 
-		dom.h2('Import'),
-		dom.p('Import messages from a .zip or .tgz file with maildirs and/or mbox files.'),
-		importForm=dom.form(
-			async function submit(e: SubmitEvent) {
-				e.preventDefault()
-				e.stopPropagation()
-
-				const request = async (): Promise<api.ImportProgress> => {
-					return new Promise((resolve, reject) => {
-						// Browsers can do everything. Except show a progress bar while uploading...
-						let progressPercentage: HTMLElement
-						dom._kids(importProgress,
-							dom.div(
-								dom.div('Uploading... ', progressPercentage=dom.span()),
-							),
-						)
-						importProgress.style.display = ''
+		dom.div(featureHidden('import'),
+			dom.h2('Import'),
+			dom.p('Import messages from a .zip or .tgz file with maildirs and/or mbox files.'),
+			importForm=dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
 
-						const xhr = new window.XMLHttpRequest()
-						xhr.open('POST', 'import', true)
-						xhr.setRequestHeader('x-mox-csrf', localStorageGet('webaccountcsrftoken') || '')
-						xhr.upload.addEventListener('progress', (e) => {
-							if (!e.lengthComputable) {
-								return
-							}
-							const pct = Math.floor(100*e.loaded/e.total)
-							dom._kids(progressPercentage, pct+'%')
-						})
-						xhr.addEventListener('load', () => {
-							console.log('upload done', {xhr: xhr, status: xhr.status})
-							if (xhr.status !== 200) {
-								reject({message: xhr.status === 400 || xhr.status === 500 ? xhr.responseText : 'status '+xhr.status})
-								return
-							}
-							let resp: api.ImportProgress
-							try {
-								resp = api.parser.ImportProgress(JSON.parse(xhr.responseText))
-							} catch (err) {
-								reject({message: 'parsing response json: '+errmsg(err)})
-								return
-							}
-							resolve(resp)
+					const request = async (): Promise<api.ImportProgress> => {
+						return new Promise((resolve, reject) => {
+							// Browsers can do everything. Except show a progress bar while uploading...
+							let progressPercentage: HTMLElement
+							dom._kids(importProgress,
+								dom.div(
+									dom.div('Uploading... ', progressPercentage=dom.span()),
+								),
+							)
+							importProgress.style.display = ''
+
+							const xhr = new window.XMLHttpRequest()
+							xhr.open('POST', 'import', true)
+							xhr.setRequestHeader('x-mox-csrf', localStorageGet('webaccountcsrftoken') || '')
+							xhr.upload.addEventListener('progress', (e) => {
+								if (!e.lengthComputable) {
+									return
+								}
+								const pct = Math.floor(100*e.loaded/e.total)
+								dom._kids(progressPercentage, pct+'%')
+							})
+							xhr.addEventListener('load', () => {
+								console.log('upload done', {xhr: xhr, status: xhr.status})
+								if (xhr.status !== 200) {
+									reject({message: xhr.status === 400 || xhr.status === 500 ? xhr.responseText : 'status '+xhr.status})
+									return
+								}
+								let resp: api.ImportProgress
+								try {
+									resp = api.parser.ImportProgress(JSON.parse(xhr.responseText))
+								} catch (err) {
+									reject({message: 'parsing response json: '+errmsg(err)})
+									return
+								}
+								resolve(resp)
+							})
+							xhr.addEventListener('error', (e) => reject({message: 'upload error', event: e}))
+							xhr.addEventListener('abort', (e) => reject({message: 'upload aborted', event: e}))
+							xhr.send(new window.FormData(importForm))
 						})
-						xhr.addEventListener('error', (e) => reject({message: 'upload error', event: e}))
-						xhr.addEventListener('abort', (e) => reject({message: 'upload aborted', event: e}))
-						xhr.send(new window.FormData(importForm))
-					})
-				}
-				try {
-					const p = request()
-					importFieldset.disabled = true
-					const result = await p
-
+					}
 					try {
-						window.sessionStorage.setItem('ImportToken', result.Token)
+						const p = request()
+						importFieldset.disabled = true
+						const result = await p
+
+						try {
+							window.sessionStorage.setItem('ImportToken', result.Token)
+						} catch (err) {
+							console.log('storing import token in session storage', {err})
+							// Ignore error, could be some browser security thing like private browsing.
+						}
+
+						await importTrack(result.Token)
 					} catch (err) {
-						console.log('storing import token in session storage', {err})
-						// Ignore error, could be some browser security thing like private browsing.
+						console.log({err})
+						window.alert('Error: ' + errmsg(err))
+					} finally {
+						importFieldset.disabled = false
 					}
-
-					await importTrack(result.Token)
-				} catch (err) {
-					console.log({err})
-					window.alert('Error: ' + errmsg(err))
-				} finally {
-					importFieldset.disabled = false
-				}
-			},
-			importFieldset=dom.fieldset(
-				dom.div(
-					style({marginBottom: '1ex'}),
-					dom.label(
-						dom.div(style({marginBottom: '.5ex'}), 'File'),
-						dom.input(attr.type('file'), attr.required(''), attr.name('file'), function focus() {
-							mailboxFileHint.style.display = ''
-						}),
+				},
+				importFieldset=dom.fieldset(
+					dom.div(
+						style({marginBottom: '1ex'}),
+						dom.label(
+							dom.div(style({marginBottom: '.5ex'}), 'File'),
+							dom.input(attr.type('file'), attr.required(''), attr.name('file'), function focus() {
+								mailboxFileHint.style.display = ''
+							}),
+						),
+						mailboxFileHint=dom.p(style({display: 'none', fontStyle: 'italic', marginTop: '.5ex'}), 'This file must either be a zip file or a gzipped tar file with mbox and/or maildir mailboxes. For maildirs, an optional file "dovecot-keywords" is read additional keywords, like Forwarded/Junk/NotJunk. If an imported mailbox already exists by name, messages are added to the existing mailbox. If a mailbox does not yet exist it will be created. Messages are not deduplicated, importing them twice will result in duplicates.'),
 					),
-					mailboxFileHint=dom.p(style({display: 'none', fontStyle: 'italic', marginTop: '.5ex'}), 'This file must either be a zip file or a gzipped tar file with mbox and/or maildir mailboxes. For maildirs, an optional file "dovecot-keywords" is read additional keywords, like Forwarded/Junk/NotJunk. If an imported mailbox already exists by name, messages are added to the existing mailbox. If a mailbox does not yet exist it will be created. Messages are not deduplicated, importing them twice will result in duplicates.'),
-				),
-				dom.div(
-					style({marginBottom: '1ex'}),
-					dom.label(
-						dom.div(style({marginBottom: '.5ex'}), 'Skip mailbox prefix (optional)'),
-						dom.input(attr.name('skipMailboxPrefix'), function focus() {
-							mailboxPrefixHint.style.display = ''
-						}),
+					dom.div(
+						style({marginBottom: '1ex'}),
+						dom.label(
+							dom.div(style({marginBottom: '.5ex'}), 'Skip mailbox prefix (optional)'),
+							dom.input(attr.name('skipMailboxPrefix'), function focus() {
+								mailboxPrefixHint.style.display = ''
+							}),
+						),
+						mailboxPrefixHint=dom.p(style({display: 'none', fontStyle: 'italic', marginTop: '.5ex'}), 'If set, any mbox/maildir path with this prefix will have it stripped before importing. For example, if all mailboxes are in a directory "Takeout", specify that path in the field above so mailboxes like "Takeout/Inbox.mbox" are imported into a mailbox called "Inbox" instead of "Takeout/Inbox".'),
+					),
+					dom.div(
+						dom.submitbutton('Upload and import'),
+						dom.p(style({fontStyle: 'italic', marginTop: '.5ex'}), 'The file is uploaded first, then its messages are imported, finally messages are matched for threading. Importing is done in a transaction, you can abort the entire import before it is finished.'),
 					),
-					mailboxPrefixHint=dom.p(style({display: 'none', fontStyle: 'italic', marginTop: '.5ex'}), 'If set, any mbox/maildir path with this prefix will have it stripped before importing. For example, if all mailboxes are in a directory "Takeout", specify that path in the field above so mailboxes like "Takeout/Inbox.mbox" are imported into a mailbox called "Inbox" instead of "Takeout/Inbox".'),
-				),
-				dom.div(
-					dom.submitbutton('Upload and import'),
-					dom.p(style({fontStyle: 'italic', marginTop: '.5ex'}), 'The file is uploaded first, then its messages are imported, finally messages are matched for threading. Importing is done in a transaction, you can abort the entire import before it is finished.'),
 				),
 			),
+			importAbortBox=dom.div(), // Outside fieldset because it gets disabled, above progress because may be scrolling it down quickly with problems.
+			importProgress=dom.div(
+				style({display: 'none'}),
+			),
+			dom.br(),
 		),
-		importAbortBox=dom.div(), // Outside fieldset because it gets disabled, above progress because may be scrolling it down quickly with problems.
-		importProgress=dom.div(
-			style({display: 'none'}),
-		),
-		dom.br(),
 
 		footer(),
 	)
@@ -1685,12 +2342,285 @@ const renderLoginAttempts = (loginAttempts: api.LoginAttempt[]) => {
 	)
 }
 
+// Render active sessions, with buttons to revoke them.
+const renderTwoFactor = (status0: api.TwoFactorStatus) => {
+	let status = status0
+	let elem: HTMLElement
+
+	const reload = async () => {
+		status = await client.TwoFactorStatus()
+		const e = render()
+		elem.replaceWith(e)
+		elem = e
+	}
+
+	const renderSetup = (setup: api.TOTPSetup) => {
+		let fieldset: HTMLFieldSetElement
+		let code: HTMLInputElement
+		return dom.div(
+			dom.p('Scan the QR code with your authenticator app, or enter the secret manually. Then enter the code shown by the app to confirm.'),
+			dom.img(attr.src(setup.QRCodePNG), attr.title(setup.URL)),
+			dom.p('Secret: ', dom.span(dom._class('literal'), setup.Secret)),
+			dom.form(
+				fieldset=dom.fieldset(
+					dom.label(
+						style({display: 'inline-block'}),
+						'Code',
+						dom.br(),
+						code=dom.input(attr.required(''), attr.autocomplete('one-time-code')),
+					),
+					' ',
+					dom.submitbutton('Confirm'),
+				),
+				async function submit(e: SubmitEvent) {
+					e.stopPropagation()
+					e.preventDefault()
+					await check(fieldset, client.TOTPSetupConfirm(code.value))
+					window.alert('Two-factor authentication is now enabled. Use app passwords for IMAP/SMTP clients.')
+					await reload()
+				},
+			),
+		)
+	}
+
+	let setupBox: HTMLElement
+	let appName: HTMLInputElement
+	let appFieldset: HTMLFieldSetElement
+	let appProtocols: {protocol: string, checkbox: HTMLInputElement}[]
+	let appIPRanges: HTMLInputElement
+
+	const render = () => dom.div(
+		status.Required && !status.Enabled ? box(yellow, 'Two-factor authentication is required for your account. Until it is enabled, you can only login to this account web interface.') : [],
+		status.Enabled ?
+			dom.p(
+				'Enabled. ',
+				status.Required ? [] : dom.clickbutton('Disable', attr.title('Disable two-factor authentication, after entering a current code from your authenticator app. App passwords are kept.'), async function click(e: {target: HTMLButtonElement}) {
+					const code = window.prompt('Code from authenticator app')
+					if (!code) {
+						return
+					}
+					await check(e.target, client.TOTPRemove(code))
+					await reload()
+				}),
+			) :
+			setupBox=dom.div(
+				dom.p('Not enabled.'),
+				dom.clickbutton('Set up two-factor authentication', async function click(e: {target: HTMLButtonElement}) {
+					const setup = await check(e.target, client.TOTPSetupStart())
+					dom._kids(setupBox, renderSetup(setup))
+				}),
+			),
+		dom.h3('App passwords', attr.title('Generated passwords for applications that cannot do two-factor authentication, like IMAP/SMTP email clients. Each app password is only valid for the selected protocols, and optionally only from the given IP ranges. App passwords can also be used without two-factor authentication.')),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Protocols'),
+					dom.th('IP ranges'),
+					dom.th('Created'),
+					dom.th('Last used'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				(status.AppPasswords || []).length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No app passwords.')),
+				(status.AppPasswords || []).map(ap =>
+					dom.tr(
+						dom.td(ap.Name),
+						dom.td((ap.Protocols || []).join(', ')),
+						dom.td((ap.IPRanges || []).join(', ') || 'any'),
+						dom.td(age(ap.Created)),
+						dom.td(ap.LastUsed.getTime() > 0 ? [age(ap.LastUsed), ap.LastIP ? ', from '+ap.LastIP : ''] : 'never'),
+						dom.td(
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+								if (!window.confirm('Are you sure? Applications using this password can no longer login.')) {
+									return
+								}
+								await check(e.target, client.AppPasswordRemove(ap.ID))
+								await reload()
+							}),
+						),
+					),
+				),
+			),
+		),
+		dom.br(),
+		dom.form(
+			appFieldset=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					'Name',
+					dom.br(),
+					appName=dom.input(attr.required(''), attr.placeholder('Laptop email client')),
+				),
+				' ',
+				(appProtocols=['imap', 'submission', 'webapi', 'caldav', 'carddav'].map(protocol => ({protocol: protocol, checkbox: dom.input(attr.type('checkbox'), protocol === 'imap' || protocol === 'submission' ? attr.checked('') : [])}))).map(p => [dom.label(p.checkbox, ' ', p.protocol), ' ']),
+				dom.label(
+					style({display: 'inline-block'}),
+					'IP ranges',
+					attr.title('Optional. If set, the app password can only be used from these networks, in CIDR notation or as single IPs, separated by commas or spaces.'),
+					dom.br(),
+					appIPRanges=dom.input(attr.placeholder('192.0.2.0/24, 2001:db8::/32')),
+				),
+				' ',
+				dom.submitbutton('Add app password'),
+			),
+			async function submit(e: SubmitEvent) {
+				e.stopPropagation()
+				e.preventDefault()
+				const protocols = appProtocols.filter(p => p.checkbox.checked).map(p => p.protocol)
+				const ipRanges = appIPRanges.value.split(/[\s,]+/).filter(s => s)
+				const password = await check(appFieldset, client.AppPasswordAdd(appName.value, protocols, ipRanges))
+				window.alert('New app password: '+password+'\n\nConfigure it in the application. It cannot be shown again.')
+				await reload()
+			},
+		),
+	)
+
+	elem = render()
+	return elem
+}
+
+const renderPasskeys = (passkeys0: api.Passkey[]) => {
+	let passkeys = passkeys0
+	let elem: HTMLElement
+
+	const reload = async () => {
+		passkeys = await client.Passkeys() || []
+		const e = render()
+		elem.replaceWith(e)
+		elem = e
+	}
+
+	let fieldset: HTMLFieldSetElement
+	let name: HTMLInputElement
+
+	const render = () => dom.div(
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Created'),
+					dom.th('Last used'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				passkeys.length ? [] : dom.tr(dom.td(attr.colspan('4'), 'No passkeys.')),
+				passkeys.map(pk =>
+					dom.tr(
+						dom.td(pk.Name),
+						dom.td(age(pk.Created)),
+						dom.td(pk.LastUsed.getTime() > 0 ? age(pk.LastUsed) : 'never'),
+						dom.td(
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+								if (!window.confirm('Are you sure? The passkey can no longer be used to login. You may also want to remove it from your device or password manager.')) {
+									return
+								}
+								await check(e.target, client.PasskeyRemove(pk.CredentialID))
+								await reload()
+							}),
+						),
+					),
+				),
+			),
+		),
+		dom.br(),
+		window.PublicKeyCredential ?
+			dom.form(
+				fieldset=dom.fieldset(
+					dom.label(
+						style({display: 'inline-block'}),
+						'Name',
+						dom.br(),
+						name=dom.input(attr.required(''), attr.placeholder('Phone')),
+					),
+					' ',
+					dom.submitbutton('Add passkey'),
+				),
+				async function submit(e: SubmitEvent) {
+					e.stopPropagation()
+					e.preventDefault()
+					await check(fieldset, (async () => {
+						const opts = await client.PasskeyRegisterStart()
+						const [clientDataJSON, attestationObject] = await passkeyCreate(opts)
+						await client.PasskeyRegister(name.value, clientDataJSON, attestationObject)
+					})())
+					await reload()
+				},
+			) :
+			dom.p('Your browser does not support passkeys.'),
+	)
+
+	elem = render()
+	return elem
+}
+
+const renderSessions = (sessions0: api.Session[]) => {
+	let sessions = sessions0
+	let elem: HTMLElement
+
+	const reload = async () => {
+		sessions = await client.Sessions() || []
+		const e = render()
+		elem.replaceWith(e)
+		elem = e
+	}
+
+	const render = () => dom.div(
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Protocol'),
+					dom.th('LoginAddress'),
+					dom.th('Remote IP'),
+					dom.th('User Agent'),
+					dom.th('Started'),
+					dom.th('Last activity'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				sessions.length ? [] : dom.tr(dom.td(attr.colspan('7'), 'No sessions.')),
+				sessions.map(s =>
+					dom.tr(
+						dom.td(s.Protocol || 'web'),
+						dom.td(s.LoginAddress),
+						dom.td(s.RemoteIP),
+						dom.td(s.UserAgent),
+						dom.td(age(s.Started)),
+						dom.td(age(s.LastActivity)),
+						dom.td(
+							s.Current ? 'Current session' : dom.clickbutton('Revoke', attr.title(s.Web ? 'Log out this session.' : 'Close this connection. Clients with a stored password can connect again.'), async function click(e: {target: HTMLButtonElement}) {
+								await check(e.target, client.SessionRevoke(s.Web, s.ID))
+								await reload()
+							}),
+						),
+					),
+				),
+			),
+		),
+		dom.br(),
+		dom.clickbutton('Revoke all other sessions', attr.title('Log out all other web sessions and close all IMAP and submission connections. Clients with a stored password can connect again, change the password to prevent that.'), async function click(e: {target: HTMLButtonElement}) {
+			if (!window.confirm('Are you sure you want to revoke all other sessions?')) {
+				return
+			}
+			const n = await check(e.target, client.SessionsRevokeAll())
+			window.alert(n+' session(s) revoked.')
+			await reload()
+		}),
+	)
+
+	elem = render()
+	return elem
+}
+
 const loginattempts = async () => {
 	const loginAttempts = await client.LoginAttempts(0)
 
 	return dom.div(
 		crumbs(
-			crumblink('Mox Account', '#'),
+			crumblink(branding.Name || 'Mox Account', '#'),
 			'Login attempts',
 		),
 		dom.h2('Login attempts'),
@@ -1833,7 +2763,7 @@ const destination = async (name: string) => {
 
 	return dom.div(
 		crumbs(
-			crumblink('Mox Account', '#'),
+			crumblink(branding.Name || 'Mox Account', '#'),
 			'Destination ' + name,
 		),
 		dom.div(
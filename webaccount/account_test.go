@@ -11,10 +11,12 @@ import (
 	"crypto/x509"
 	"encoding/json"
 	"encoding/pem"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
 	"mime/multipart"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -30,7 +32,10 @@ import (
 
 	"github.com/mjl-/bstore"
 	"github.com/mjl-/sherpa"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 
+	"github.com/mjl-/mox/admin"
 	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/junk"
@@ -38,6 +43,7 @@ import (
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/queue"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/totp"
 	"github.com/mjl-/mox/webauth"
 	"github.com/mjl-/mox/webhook"
 )
@@ -126,14 +132,14 @@ func TestAccount(t *testing.T) {
 	ctx := context.WithValue(ctxbg, requestInfoCtxKey, reqInfo)
 
 	// Missing login token.
-	tneedErrorCode(t, "user:error", func() { api.Login(ctx, "", "mjl☺@mox.example", "test1234") })
+	tneedErrorCode(t, "user:error", func() { api.Login(ctx, "", "mjl☺@mox.example", "test1234", "") })
 
 	// Login with loginToken.
 	loginCookie := &http.Cookie{Name: "webaccountlogin"}
 	loginCookie.Value = api.LoginPrep(ctx)
 	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
 
-	csrfToken := api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "test1234")
+	csrfToken := api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "test1234", "")
 	var sessionCookie *http.Cookie
 	for _, c := range respRec.Result().Cookies() {
 		if c.Name == "webaccountsession" {
@@ -148,9 +154,9 @@ func TestAccount(t *testing.T) {
 	// Valid loginToken, but bad credentials.
 	loginCookie.Value = api.LoginPrep(ctx)
 	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "badauth") })
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "baduser@mox.example", "badauth") })
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "baduser@baddomain.example", "badauth") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "badauth", "") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "baduser@mox.example", "badauth", "") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "baduser@baddomain.example", "badauth", "") })
 
 	acc2, err := store.OpenAccount(log, "disabled", false)
 	tcheck(t, err, "open account")
@@ -164,8 +170,21 @@ func TestAccount(t *testing.T) {
 	loginCookie2 := &http.Cookie{Name: "webaccountlogin"}
 	loginCookie2.Value = api.LoginPrep(loginctx2)
 	loginReqInfo2.Request.Header = http.Header{"Cookie": []string{loginCookie2.String()}}
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "test1234") })
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "bogus") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "test1234", "") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "bogus", "") })
+
+	// With two-factor authentication enabled, a code is required.
+	secret, err := acc.TOTPSetupStart(log)
+	tcheck(t, err, "totp setup start")
+	err = acc.TOTPSetupConfirm(log, totp.Code(secret, totp.Step(time.Now())-1))
+	tcheck(t, err, "totp setup confirm")
+	loginCookie.Value = api.LoginPrep(ctx)
+	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
+	tneedErrorCode(t, "user:totpRequired", func() { api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "test1234", "") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "test1234", "000000") })
+	api.Login(ctx, loginCookie.Value, "mjl☺@mox.example", "test1234", totp.Code(secret, totp.Step(time.Now())))
+	err = acc.TOTPRemove(log)
+	tcheck(t, err, "totp remove")
 
 	type httpHeaders [][2]string
 	ctJSON := [2]string{"Content-Type", "application/json; charset=utf-8"}
@@ -186,7 +205,7 @@ func TestAccount(t *testing.T) {
 		}
 		rr := httptest.NewRecorder()
 		rr.Body = &bytes.Buffer{}
-		handle(apiHandler, false, rr, req)
+		handle(apiHandler, "/", false, rr, req)
 		if rr.Code != expStatusCode {
 			t.Fatalf("got status %d, expected %d (%s)", rr.Code, expStatusCode, readBody(rr.Body))
 		}
@@ -255,11 +274,48 @@ func TestAccount(t *testing.T) {
 
 	api.SetPassword(ctx, "test1234")
 
+	// Sessions, with revoking web sessions and connections.
+	otherSession, _, err := store.SessionAdd(ctxbg, log, "mjl☺", "mjl☺@mox.example", "webmail", "10.0.0.1", "testagent")
+	tcheck(t, err, "add session")
+	nc0, nc1 := net.Pipe()
+	defer nc0.Close()
+	defer nc1.Close()
+	mox.Connections.Register(nc0, "imap", "test")
+	defer mox.Connections.Unregister(nc0)
+	mox.Connections.Activity(nc0, "imap", "mjl☺", "mjl☺@mox.example", "testclient")
+	sessions := api.Sessions(ctx)
+	tcompare(t, len(sessions), 3)
+	var webOther, conn Session
+	for _, s := range sessions {
+		if s.Web && s.RemoteIP == "10.0.0.1" {
+			webOther = s
+		} else if !s.Web {
+			conn = s
+		} else {
+			tcompare(t, [2]any{s.Current, s.Protocol}, [2]any{true, "webaccount"})
+		}
+	}
+	tcompare(t, [4]any{webOther.Protocol, webOther.UserAgent, webOther.LoginAddress, webOther.Current}, [4]any{"webmail", "testagent", "mjl☺@mox.example", false})
+	tcompare(t, [2]string{conn.Protocol, conn.UserAgent}, [2]string{"imap", "testclient"})
+	tneedErrorCode(t, "user:error", func() { api.SessionRevoke(ctx, true, 9999) })
+	tneedErrorCode(t, "user:error", func() { api.SessionRevoke(ctx, false, 9999) })
+	api.SessionRevoke(ctx, true, webOther.ID)
+	_, err = store.SessionUse(ctxbg, log, "mjl☺", otherSession, "")
+	if err == nil {
+		t.Fatalf("revoked session still usable")
+	}
+	tcompare(t, api.SessionsRevokeAll(ctx), 1)
+	_, err = nc0.Read(make([]byte, 1))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, expected os.ErrDeadlineExceeded for revoked connection", err)
+	}
+	tcompare(t, len(api.Sessions(ctx)), 1)
+
 	err = queue.Init() // For DB.
 	tcheck(t, err, "queue init")
 	defer queue.Shutdown()
 
-	account, _, _, _ := api.Account(ctx)
+	account, _, _, _, _ := api.Account(ctx)
 
 	// Check we don't see the alias member list.
 	tcompare(t, len(account.Aliases), 1)
@@ -272,6 +328,22 @@ func TestAccount(t *testing.T) {
 		},
 	})
 
+	// Alias owner and member self-service.
+	tcompare(t, account.OwnedAliases, []string{"support@mox.example"})
+	tcompare(t, api.AliasesOwned(ctx), []OwnedAlias{{"support@mox.example", config.AliasPostMembers, []string{"mjl☺@mox.example"}, []string{"mjl☺@mox.example"}}})
+	api.AliasOwnerMembersAdd(ctx, "support@mox.example", []string{"other@mox.example"})
+	tneedErrorCode(t, "user:error", func() { api.AliasOwnerMembersAdd(ctx, "support@mox.example", []string{"bogus@mox.example"}) }) // Not a local address.
+	tneedErrorCode(t, "user:error", func() { api.AliasOwnerMembersAdd(ctx, "bogus@mox.example", []string{"other@mox.example"}) })   // Not owner.
+	api.AliasMemberSave(ctx, "support@mox.example", "other@mox.example", config.AliasMember{Mailbox: "Lists"})
+	tneedErrorCode(t, "user:error", func() {
+		api.AliasMemberSave(ctx, "support@mox.example", "bogus@mox.example", config.AliasMember{NoDelivery: true})
+	}) // Not a member.
+	account, _, _, _, _ = api.Account(ctx)
+	tcompare(t, len(account.Aliases), 2)
+	api.AliasLeave(ctx, "support@mox.example", "other@mox.example")
+	tneedErrorCode(t, "user:error", func() { api.AliasLeave(ctx, "support@mox.example", "other@mox.example") })                       // No longer a member.
+	tneedErrorCode(t, "user:error", func() { api.AliasOwnerMembersRemove(ctx, "support@mox.example", []string{"mjl☺@mox.example"}) }) // Alias needs a member.
+
 	api.DestinationSave(ctx, "mjl☺@mox.example", account.Destinations["mjl☺@mox.example"], account.Destinations["mjl☺@mox.example"]) // todo: save modified value and compare it afterwards
 
 	api.AccountSaveFullName(ctx, account.FullName+" changed") // todo: check if value was changed
@@ -302,7 +374,7 @@ func TestAccount(t *testing.T) {
 		r.Header.Add("x-mox-csrf", string(csrfToken))
 		r.Header.Add("Cookie", cookieOK.String())
 		w := httptest.NewRecorder()
-		handle(apiHandler, false, w, r)
+		handle(apiHandler, "/", false, w, r)
 		if w.Code != http.StatusOK {
 			t.Fatalf("import, got status code %d, expected 200: %s", w.Code, w.Body.Bytes())
 		}
@@ -394,7 +466,7 @@ func TestAccount(t *testing.T) {
 		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		r.Header.Add("Cookie", cookieOK.String())
 		w := httptest.NewRecorder()
-		handle(apiHandler, false, w, r)
+		handle(apiHandler, "/", false, w, r)
 		if w.Code != http.StatusOK {
 			t.Fatalf("export, got status code %d, expected 200: %s", w.Code, w.Body.Bytes())
 		}
@@ -484,6 +556,37 @@ func TestAccount(t *testing.T) {
 	tcompare(t, errmsg, "")
 	tneedErrorCode(t, "user:error", func() { api.IncomingWebhookTest(ctx, "bogus", "", webhook.Incoming{}) })
 
+	// Branding and disabled features through the web config of the domain.
+	err = admin.DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.Web = &config.DomainWeb{Name: "Example Mail", Color: "#123456", DisabledFeatures: []string{config.WebFeatureWebhooks, config.WebFeatureExport}}
+		return nil
+	})
+	tcheck(t, err, "save domain web config")
+	tneedErrorCode(t, "user:error", func() { api.IncomingWebhookSave(ctx, "http://localhost:1234", "Basic base64") })
+	_, _, _, _, disabledFeatures := api.Account(ctx)
+	tcompare(t, disabledFeatures, []string{config.WebFeatureWebhooks, config.WebFeatureExport})
+	testHTTP("POST", "/export", httpHeaders{hdrSessionOK, hdrCSRFOK}, http.StatusForbidden, nil, nil)
+	for _, tc := range []struct {
+		host, path, expBody string
+	}{
+		{"mail.mox.example", "/branding.js", `window.moxBranding = {"Name":"Example Mail"}`},
+		{"mox.example:443", "/branding.css", "color: #123456"},
+		{"other.example", "/branding.js", `window.moxBranding = {}`},
+	} {
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Host = tc.host
+		rr := httptest.NewRecorder()
+		handle(apiHandler, "/", false, rr, req)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), tc.expBody) {
+			t.Fatalf("branding for host %s, path %s: got status %d, body %q, expected %q", tc.host, tc.path, rr.Code, rr.Body.String(), tc.expBody)
+		}
+	}
+	err = admin.DomainSave(ctxbg, "mox.example", func(d *config.Domain) error {
+		d.Web = nil
+		return nil
+	})
+	tcheck(t, err, "restore domain web config")
+
 	api.FromIDLoginAddressesSave(ctx, []string{"mjl☺@mox.example"})
 	api.FromIDLoginAddressesSave(ctx, []string{"mjl☺@mox.example", "mjl☺+fromid@mox.example"})
 	api.FromIDLoginAddressesSave(ctx, []string{})
@@ -511,6 +614,88 @@ func TestAccount(t *testing.T) {
 	api.RejectsSave(ctx, "Rejects", false)
 	api.RejectsSave(ctx, "", false) // Restore.
 
+	api.ScreeningSave(ctx, &config.Screening{Mailbox: "Screened"})
+	account, _, _, _, _ = api.Account(ctx)
+	tcompare(t, account.Screening, &config.Screening{Mailbox: "Screened"})
+	api.ScreeningSave(ctx, nil) // Restore.
+
+	api.CategoriesSave(ctx, &config.Categories{Newsletters: &config.Category{Mailbox: "Newsletters"}, Notifications: &config.Category{Domains: []string{"github.com"}}})
+	account, _, _, _, _ = api.Account(ctx)
+	tcompare(t, account.Categories.Newsletters.Mailbox, "Newsletters")
+	tcompare(t, account.Categories.Notifications.Domains, []string{"github.com"})
+	tcompare(t, account.Categories.Social == nil, true)
+	api.CategoriesSave(ctx, nil) // Restore.
+
+	e := api.SenderListAdd(ctx, "Remote@example.org", store.SenderListAllow)
+	tcompare(t, e.Pattern, "remote@example.org")
+	api.SenderListAdd(ctx, "remote@example.org", store.SenderListReject)
+	api.SenderListAdd(ctx, "*.example.org", store.SenderListDiscard)
+	senders := api.SenderList(ctx)
+	tcompare(t, len(senders), 2)
+	tcompare(t, senders[1].Action, store.SenderListReject)
+	tneedErrorCode(t, "user:error", func() { api.SenderListAdd(ctx, "bogus@", store.SenderListReject) })
+	tneedErrorCode(t, "user:error", func() { api.SenderListAdd(ctx, "example.org", "bogus") })
+	api.SenderListRemove(ctx, e.ID)
+	tneedErrorCode(t, "user:error", func() { api.SenderListRemove(ctx, e.ID) }) // Absent.
+
+	identities := []config.Identity{
+		{Address: "mjl☺@mox.example", DisplayName: "mjl", Signature: []string{"mjl"}, SignatureHTML: "<b>mjl</b>", Default: true},
+		{Address: "mjl☺@mox.example", DisplayName: "Support", ReplyQuoting: "top", ReplyTo: "support@mox.example"},
+	}
+	api.IdentitiesSave(ctx, identities)
+	account, _, _, _, _ = api.Account(ctx)
+	tcompare(t, account.Identities, identities)
+	tneedErrorCode(t, "user:error", func() { api.IdentitiesSave(ctx, []config.Identity{{Address: "bogus"}}) })
+	tneedErrorCode(t, "user:error", func() { api.IdentitiesSave(ctx, []config.Identity{{Address: "other@other.example"}}) })
+	tneedErrorCode(t, "user:error", func() {
+		api.IdentitiesSave(ctx, []config.Identity{{Address: "mjl☺@mox.example", Default: true}, {Address: "mjl☺@mox.example", DisplayName: "x", Default: true}})
+	})
+	api.IdentitiesSave(ctx, nil) // Restore.
+
+	// OpenPGP keys.
+	pgpArmor := func(e *openpgp.Entity, private bool) string {
+		var b bytes.Buffer
+		typ := openpgp.PublicKeyType
+		if private {
+			typ = openpgp.PrivateKeyType
+		}
+		w, err := armor.Encode(&b, typ, nil)
+		tcheck(t, err, "armor")
+		if private {
+			err = e.SerializePrivate(w, nil)
+		} else {
+			err = e.Serialize(w)
+		}
+		tcheck(t, err, "serialize key")
+		err = w.Close()
+		tcheck(t, err, "close armor")
+		return b.String()
+	}
+	pgpEntity, err := openpgp.NewEntity("mjl", "", "mjl☺@mox.example", nil)
+	tcheck(t, err, "new pgp key")
+	pgpk := api.PGPKeyAdd(ctx, pgpArmor(pgpEntity, false))
+	tcompare(t, pgpk.Private, false)
+	tcompare(t, pgpk.Addresses, []string{"mjl☺@mox.example"})
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyAdd(ctx, pgpArmor(pgpEntity, false)) })               // Already present.
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyAdd(ctx, "bogus") })                                  // Not a key.
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyAdd(ctx, pgpEd25519Key) })                            // Unsupported algorithm.
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyPublish(ctx, pgpk.Fingerprint, true) })               // No private key.
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyPublish(ctx, "0000000000000000000000000000", true) }) // Unknown key.
+	pgpk = api.PGPKeyAdd(ctx, pgpArmor(pgpEntity, true))                                                     // Adds private key to existing key.
+	tcompare(t, pgpk.Private, true)
+	api.PGPKeyPublish(ctx, pgpk.Fingerprint, true)
+	pgpkl := api.PGPKeys(ctx)
+	tcompare(t, len(pgpkl), 1)
+	tcompare(t, pgpkl[0].Publish, true)
+	otherEntity, err := openpgp.NewEntity("other", "", "other@other.example", nil)
+	tcheck(t, err, "new pgp key")
+	otherk := api.PGPKeyAdd(ctx, pgpArmor(otherEntity, true))
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyPublish(ctx, otherk.Fingerprint, true) }) // Address not of account.
+	api.PGPKeyRemove(ctx, otherk.Fingerprint)
+	api.PGPKeyRemove(ctx, pgpk.Fingerprint)
+	tneedErrorCode(t, "user:error", func() { api.PGPKeyRemove(ctx, pgpk.Fingerprint) })
+	tcompare(t, len(api.PGPKeys(ctx)), 0)
+
 	// Make cert for TLSPublicKey.
 	certBuf := fakeCert(t)
 	var b bytes.Buffer
@@ -566,3 +751,19 @@ func fakeCert(t *testing.T) []byte {
 	tcheck(t, err, "making certificate")
 	return localCertBuf
 }
+
+// Ed25519 public key, not supported by golang.org/x/crypto/openpgp.
+const pgpEd25519Key = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatGW4BYJKwYBBAHaRw8BAQdAXBfDErIhQ29EeMXPUgUxduQQfWrGatATaM5r
++2b+/r+0GlRlc3QgPGVkMjU1MTlAbW94LmV4YW1wbGU+iJAEExYIADgWIQSk/A2J
+EcI+oFI1olIaGX2/EP93aQUCatGW4AIbAwULCQgHAgYVCgkICwIEFgIDAQIeAQIX
+gAAKCRAaGX2/EP93aQwBAQC1ED/ortPLZSdd8RoYO6/A+Pe6JCQxYUS/j/2aCrVT
+AAD/SHKYSVZFdR9wbbbt2QbXGGXEpad0pTocYfa7P45rKAS4OARq0ZbhEgorBgEE
+AZdVAQUBAQdA3nXd6gemoVMPm924iDC2SAbeVBGDpVIZPL1YWY+XsQIDAQgHiHgE
+GBYIACAWIQSk/A2JEcI+oFI1olIaGX2/EP93aQUCatGW4QIbDAAKCRAaGX2/EP93
+aefTAQCSMzEEYTVKWdDcOOcPfCeV/IIEtEZ56/AZHXXBVo6xmwD/QopOyNR/ZXX2
+W/guwBAd8SOs9x1l3/qFRlyDQ7w5yAQ=
+=j5vG
+-----END PGP PUBLIC KEY BLOCK-----
+`
@@ -2,6 +2,84 @@
 
 namespace api {
 
+// PasskeyGetOptions are the parameters for navigator.credentials.get in the
+// browser for a passkey login. No credentials are specified: the browser lets
+// the user choose from the passkeys registered for the site.
+export interface PasskeyGetOptions {
+	Challenge?: string | null
+	RPID: string
+	Timeout: number  // In milliseconds.
+}
+
+// PasskeyAssertion is the response from navigator.credentials.get.
+export interface PasskeyAssertion {
+	CredentialID?: string | null
+	ClientDataJSON?: string | null
+	AuthenticatorData?: string | null
+	Signature?: string | null
+}
+
+// PasswordStatus is the state of the password and recovery codes of the account.
+export interface PasswordStatus {
+	Changed: Date  // Zero if not known, for passwords set with older versions.
+	Expires: Date  // Zero if the password doesn't expire by age.
+	Expired: boolean  // If set, a new password must be set before logging in with other protocols.
+	RecoveryCodes: number  // Number of unused recovery codes.
+}
+
+// TwoFactorStatus is the state of two-factor authentication for the account.
+export interface TwoFactorStatus {
+	Enabled: boolean  // Whether a TOTP code is required for web logins.
+	Required: boolean  // Whether the admin requires two-factor authentication for the account.
+	AppPasswords?: AppPassword[] | null  // For protocols other than the web interfaces.
+}
+
+// AppPassword is a generated password for a single application, e.g. an IMAP/SMTP
+// client, for use instead of the account password when two-factor authentication
+// is enabled. Only valid for its protocols, and if IP ranges are set, only for
+// connections from those ranges. Only a hash is stored.
+export interface AppPassword {
+	ID: number
+	Created: Date
+	Name: string  // Description, e.g. "Laptop".
+	Protocols?: string[] | null  // Protocols this password can be used for, see AppPasswordProtocols.
+	IPRanges?: string[] | null  // If non-empty, networks in CIDR notation the password can be used from, e.g. 192.0.2.0/24 or 2001:db8::/32.
+	LastUsed: Date
+	LastIP: string  // Remote IP of last use.
+}
+
+// TOTPSetup has the parameters for configuring an authenticator app.
+export interface TOTPSetup {
+	Secret: string  // Base32, for manual entry.
+	URL: string  // otpauth URL, as in the QR code.
+	QRCodePNG: string  // Data URL with PNG image of QR code.
+}
+
+// Passkey is a WebAuthn public key credential for logging into the web interfaces
+// without password, or as second factor after the password.
+export interface Passkey {
+	CredentialID: string  // Raw-url-base64-encoded credential ID, as chosen by the authenticator.
+	Created: Date
+	Name: string  // Descriptive name to identify the passkey, e.g. the device or password manager.
+	Account: string  // Account the passkey authenticates. Empty for passkeys of the admin.
+	LoginAddress: string  // Login address for sessions, the address used when the passkey was registered. Empty for admin.
+	SignCount: number  // Signature counter of authenticator, zero if not implemented.
+	LastUsed: Date  // Zero if not used.
+}
+
+// PasskeyCreateOptions are the parameters for navigator.credentials.create in
+// the browser, for registering a new passkey.
+export interface PasskeyCreateOptions {
+	Challenge?: string | null
+	RPID: string
+	RPName: string
+	UserID?: string | null
+	UserName: string
+	Algorithms?: number[] | null  // COSE algorithm identifiers.
+	ExcludeCredentials?: (string | null)[] | null  // Already registered, the browser won't register a second passkey on the same authenticator.
+	Timeout: number  // In milliseconds.
+}
+
 export interface Account {
 	OutgoingWebhook?: OutgoingWebhook | null
 	IncomingWebhook?: IncomingWebhook | null
@@ -19,24 +97,37 @@ export interface Account {
 	KeepRejects: boolean
 	AutomaticJunkFlags: AutomaticJunkFlags
 	JunkFilter?: JunkFilter | null  // todo: sane defaults for junkfilter
+	JunkReevaluation?: JunkReevaluation | null
+	Screening?: Screening | null
+	Categories?: Categories | null
 	MaxOutgoingMessagesPerDay: number
 	MaxFirstTimeRecipientsPerDay: number
 	NoFirstTimeSenderDelay: boolean
 	NoCustomPassword: boolean
+	PasswordPolicy?: PasswordPolicy | null
+	RequireTwoFactor: boolean
 	Routes?: Route[] | null
+	OutgoingHeaderRules?: OutgoingHeaderRules | null
+	OutgoingFooter?: OutgoingFooter | null
+	Identities?: Identity[] | null
+	Delegations?: Delegation[] | null
 	DNSDomain: Domain  // Parsed form of Domain.
 	Aliases?: AddressAlias[] | null
+	OwnedAliases?: string[] | null  // Addresses of aliases with an address of this account as owner.
 }
 
 export interface OutgoingWebhook {
 	URL: string
 	Authorization: string
 	Events?: string[] | null
+	SigningKey: string
+	Transcript: boolean
 }
 
 export interface IncomingWebhook {
 	URL: string
 	Authorization: string
+	SigningKey: string
 }
 
 export interface Destination {
@@ -83,6 +174,9 @@ export interface AutomaticJunkFlags {
 
 export interface JunkFilter {
 	Threshold: number
+	TagThreshold: number
+	JunkThreshold: number
+	HighAction: string
 	Onegrams: boolean
 	Twograms: boolean
 	Threegrams: boolean
@@ -92,6 +186,40 @@ export interface JunkFilter {
 	RareWords: number
 }
 
+export interface JunkReevaluation {
+	Window: number
+	Sender: boolean
+	DNSBL: boolean
+	Spamtrap: boolean
+	Notify: boolean
+}
+
+export interface Screening {
+	Mailbox: string
+}
+
+// Categories configures classification of incoming bulk messages. A nil
+// category is not used.
+export interface Categories {
+	Newsletters?: Category | null
+	Notifications?: Category | null
+	Social?: Category | null
+}
+
+// Category is a class of bulk messages, see Categories.
+export interface Category {
+	Mailbox: string
+	Domains?: string[] | null
+}
+
+export interface PasswordPolicy {
+	MinLength: number
+	MinEntropy: number
+	MaxAge: number
+	LockoutFailures: number
+	LockoutPeriod: number
+}
+
 export interface Route {
 	FromDomain?: string[] | null
 	ToDomain?: string[] | null
@@ -101,10 +229,50 @@ export interface Route {
 	ToDomainASCII?: string[] | null
 }
 
+// OutgoingHeaderRules are changes to the message header of outgoing messages.
+// Removals are done first, then From and Reply-To changes, then additions.
+export interface OutgoingHeaderRules {
+	Remove?: string[] | null
+	FromDisplayName: string
+	ReplyTo: string
+	Add?: string[] | null
+}
+
+// OutgoingFooter is text added to the end of the message text of outgoing
+// messages. It is added to text/plain and text/html parts that form the message
+// text, not to attachments. Messages that are signed or encrypted, e.g. with
+// S/MIME or PGP/MIME, are left unchanged, the footer would invalidate the
+// signature.
+export interface OutgoingFooter {
+	Text?: string[] | null
+	HTML: string
+}
+
+// Identity is an address with display name and signature an account can send
+// messages as in the webmail.
+export interface Identity {
+	Address: string
+	DisplayName: string
+	Signature?: string[] | null
+	SignatureHTML: string
+	ReplyQuoting: string
+	ReplyTo: string
+	Default: boolean
+}
+
+// Delegation gives another account access to mailboxes of an account.
+export interface Delegation {
+	Account: string
+	Mailboxes?: string[] | null
+	Write: boolean
+	Send: string
+}
+
 export interface AddressAlias {
 	SubscriptionAddress: string
 	Alias: Alias  // Without members.
 	MemberAddresses?: string[] | null  // Only if allowed to see.
+	Member: AliasMember  // Delivery preferences of the subscription address.
 }
 
 export interface Alias {
@@ -112,15 +280,35 @@ export interface Alias {
 	PostPublic: boolean
 	ListMembers: boolean
 	AllowMsgFrom: boolean
+	PostPolicy: string
+	Owners?: string[] | null
+	ListHeaders: boolean
+	Members?: { [key: string]: AliasMember }
+	List?: AliasList | null
 	LocalpartStr: string  // In encoded form.
 	Domain: Domain
 	ParsedAddresses?: AliasAddress[] | null  // Matches addresses.
+	ParsedOwners?: AliasAddress[] | null  // Matches Owners.
+}
+
+// AliasMember holds delivery preferences of a member of an alias.
+export interface AliasMember {
+	NoDelivery: boolean
+	Mailbox: string
+}
+
+// AliasList configures the mailing list features of an alias.
+export interface AliasList {
+	Subscribe: boolean
+	Archive: boolean
+	BounceLimit: number
 }
 
 export interface AliasAddress {
 	Address: Address  // Parsed address.
 	AccountName: string  // Looked up.
 	Destination: Destination  // Belonging to address.
+	Member: AliasMember  // Delivery preferences, from Alias.Members.
 }
 
 // Address is a parsed email address.
@@ -139,6 +327,15 @@ export interface Suppression {
 	OriginalAddress: string  // Unicode. Address that caused this suppression.
 	Manual: boolean
 	Reason: string
+	BounceClass: BounceClass  // For automatically added suppressions, the classification of the delivery failure that caused it.
+}
+
+// OwnedAlias is an alias with an address of the account as owner.
+export interface OwnedAlias {
+	Address: string  // Alias address.
+	PostPolicy: string  // Effective post policy: members, anyone or moderated.
+	Members?: string[] | null  // Member addresses.
+	Owners?: string[] | null
 }
 
 // ImportProgress is returned after uploading a file to import.
@@ -159,8 +356,19 @@ export interface Outgoing {
 	WebhookQueued: Date  // When webhook was first queued for delivery.
 	SMTPCode: number  // Optional, for errors only, e.g. 451, 550. See package smtp for definitions.
 	SMTPEnhancedCode: string  // Optional, for errors only, e.g. 5.1.1.
+	BounceClass: BounceClass  // Optional, for failed and delayed events with an SMTP error: "hard", "soft", "block" or "policy".
 	Error: string  // Error message while delivering, or from DSN from remote, if any.
 	Extra?: { [key: string]: string }  // Extra fields set for message during submit, through webapi call or through X-Mox-Extra-* headers during SMTP submission.
+	Recipient: string  // Address of the recipient this event is about, as used in SMTP RCPT TO.
+	Attempts: number  // Number of delivery attempts made so far.
+	MaxAttempts: number  // Maximum number of delivery attempts before giving up, for delayed events.
+	NextAttempt?: Date | null  // When the next delivery attempt is scheduled, for delayed events.
+	RemoteMTA: string  // Host name and IP address of remote server, if a connection was made.
+	SMTPResponse?: string[] | null  // Full response from remote server, one or more lines.
+	TLS: string  // TLS version and cipher suite, e.g. "tls1.3 tls_aes_128_gcm_sha256". Empty if TLS was not used.
+	MTASTS: boolean  // Whether the TLS connection was verified according to an MTA-STS policy.
+	DANE: boolean  // Whether the TLS connection was verified with DANE.
+	Transcript: string  // SMTP protocol transcript, without authentication and message data. Only included if enabled for the webhook.
 }
 
 // Incoming is the data sent to a webhook for incoming deliveries over SMTP.
@@ -210,6 +418,27 @@ export interface IncomingMeta {
 	Automated: boolean  // Whether this message was automated and should not receive automated replies. E.g. out of office or mailing list messages.
 }
 
+// SenderListEntry is an entry in the allow or block list of senders of an
+// account, matched against the message From address of incoming messages, and
+// for rejects and discards also against the SMTP MAIL FROM address.
+export interface SenderListEntry {
+	ID: number
+	Created: Date
+	Pattern: string  // Lower case address like "user@example.org", domain like "example.org", or wildcard domain like "*.example.org" matching the domain and all its subdomains. Domains in unicode.
+	Action: SenderListAction
+}
+
+// PGPKey is an OpenPGP key of the account, without private key material.
+export interface PGPKey {
+	Fingerprint: string
+	KeyID: string
+	Created: Date
+	Addresses?: string[] | null
+	PublicKey: string  // Armored.
+	Private: boolean  // Whether the private key is present.
+	Publish: boolean  // Whether served in the Web Key Directory.
+}
+
 // TLSPublicKey is a public key for use with TLS client authentication based on the
 // public key of the certificate.
 export interface TLSPublicKey {
@@ -233,7 +462,7 @@ export interface LoginAttempt {
 	Last: Date  // Last has an index for efficient removal of entries after 30 days.
 	First: Date
 	Count: number  // Number of login attempts for the combination of fields below.
-	AccountName: string  // Admin logins use "(admin)". If no account is known, "-" is used. AccountName has an index for efficiently removing failed login attempts at the end of the list when there are too many, and for efficiently removing all records for an account.
+	AccountName: string  // Admin logins use "(admin)", or "(admin:<user>)" for admin users. If no account is known, "-" is used. AccountName has an index for efficiently removing failed login attempts at the end of the list when there are too many, and for efficiently removing all records for an account.
 	LoginAddress: string  // Empty for attempts to login in as admin.
 	RemoteIP: string
 	LocalIP: string
@@ -245,6 +474,20 @@ export interface LoginAttempt {
 	Result: AuthResult
 }
 
+// Session is an active session of the account: a login to the webmail or account
+// web interface, or an authenticated IMAP or submission connection.
+export interface Session {
+	Web: boolean  // Web login. Otherwise an IMAP/submission connection. Together with ID, identifies the session.
+	ID: number  // For revoking.
+	Protocol: string  // "webmail", "webaccount", "imap" or "submission". Empty for older web sessions.
+	RemoteIP: string
+	UserAgent: string
+	LoginAddress: string
+	Started: Date
+	LastActivity: Date
+	Current: boolean  // Session of this request.
+}
+
 export type CSRFToken = string
 
 // Localpart is a decoded local part of an email address, before the "@".
@@ -253,6 +496,23 @@ export type CSRFToken = string
 // Localparts are in Unicode NFC.
 export type Localpart = string
 
+// BounceClass is the classification of a delivery failure, based on the SMTP
+// codes and response text from the remote server, or from an incoming DSN.
+export enum BounceClass {
+	// Recipient address does not exist or does not accept email anymore. Sending to
+	// it again is pointless.
+	BounceHard = "hard",
+	// Temporary condition at the recipient, e.g. a full mailbox, or a remote server
+	// that is unavailable. Delivery may succeed later.
+	BounceSoft = "soft",
+	// Our server (its IP or domain) is blocked by the recipient, e.g. due to listing
+	// in a DNS blocklist or bad reputation. Not specific to the recipient address.
+	BounceBlock = "block",
+	// Message was rejected due to a policy, e.g. a content filter considering it
+	// spam, failed SPF/DKIM/DMARC, or a message that is too large.
+	BouncePolicy = "policy",
+}
+
 // OutgoingEvent is an activity for an outgoing delivery. Either generated by the
 // queue, or through an incoming DSN (delivery status notification) message.
 export enum OutgoingEvent {
@@ -279,6 +539,14 @@ export enum OutgoingEvent {
 	EventUnrecognized = "unrecognized",
 }
 
+// SenderListAction is the action for messages from a sender matching an entry
+// in the sender lists of an account.
+export enum SenderListAction {
+	SenderListAllow = "allow",  // Accept without junk filtering, if the message From address is verified.
+	SenderListReject = "reject",  // Reject during SMTP.
+	SenderListDiscard = "discard",  // Accept during SMTP, but don't deliver.
+}
+
 // AuthResult is the result of a login attempt.
 export enum AuthResult {
 	AuthSuccess = "ok",
@@ -288,44 +556,82 @@ export enum AuthResult {
 	AuthBadChannelBinding = "badchanbind",
 	AuthBadProtocol = "badprotocol",
 	AuthLoginDisabled = "logindisabled",
+	AuthLoginLocked = "loginlocked",
+	AuthPasswordExpired = "passwordexpired",
+	AuthAppPasswordRequired = "apppasswordrequired",  // Account password used while two-factor authentication requires an app password.
+	AuthTOTPRequired = "totprequired",  // Valid password for web login, but second factor still needed.
+	AuthTwoFactorSetup = "twofactorsetup",  // Two-factor authentication required but not yet set up.
 	AuthError = "error",
 	AuthAborted = "aborted",
 }
 
-export const structTypes: {[typename: string]: boolean} = {"Account":true,"Address":true,"AddressAlias":true,"Alias":true,"AliasAddress":true,"AutomaticJunkFlags":true,"Destination":true,"Domain":true,"ImportProgress":true,"Incoming":true,"IncomingMeta":true,"IncomingWebhook":true,"JunkFilter":true,"LoginAttempt":true,"NameAddress":true,"Outgoing":true,"OutgoingWebhook":true,"Route":true,"Ruleset":true,"Structure":true,"SubjectPass":true,"Suppression":true,"TLSPublicKey":true}
-export const stringsTypes: {[typename: string]: boolean} = {"AuthResult":true,"CSRFToken":true,"Localpart":true,"OutgoingEvent":true}
+export const structTypes: {[typename: string]: boolean} = {"Account":true,"Address":true,"AddressAlias":true,"Alias":true,"AliasAddress":true,"AliasList":true,"AliasMember":true,"AppPassword":true,"AutomaticJunkFlags":true,"Categories":true,"Category":true,"Delegation":true,"Destination":true,"Domain":true,"Identity":true,"ImportProgress":true,"Incoming":true,"IncomingMeta":true,"IncomingWebhook":true,"JunkFilter":true,"JunkReevaluation":true,"LoginAttempt":true,"NameAddress":true,"Outgoing":true,"OutgoingFooter":true,"OutgoingHeaderRules":true,"OutgoingWebhook":true,"OwnedAlias":true,"PGPKey":true,"Passkey":true,"PasskeyAssertion":true,"PasskeyCreateOptions":true,"PasskeyGetOptions":true,"PasswordPolicy":true,"PasswordStatus":true,"Route":true,"Ruleset":true,"Screening":true,"SenderListEntry":true,"Session":true,"Structure":true,"SubjectPass":true,"Suppression":true,"TLSPublicKey":true,"TOTPSetup":true,"TwoFactorStatus":true}
+export const stringsTypes: {[typename: string]: boolean} = {"AuthResult":true,"BounceClass":true,"CSRFToken":true,"Localpart":true,"OutgoingEvent":true,"SenderListAction":true}
 export const intsTypes: {[typename: string]: boolean} = {}
 export const types: TypenameMap = {
-	"Account": {"Name":"Account","Docs":"","Fields":[{"Name":"OutgoingWebhook","Docs":"","Typewords":["nullable","OutgoingWebhook"]},{"Name":"IncomingWebhook","Docs":"","Typewords":["nullable","IncomingWebhook"]},{"Name":"FromIDLoginAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"KeepRetiredMessagePeriod","Docs":"","Typewords":["int64"]},{"Name":"KeepRetiredWebhookPeriod","Docs":"","Typewords":["int64"]},{"Name":"LoginDisabled","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]},{"Name":"Destinations","Docs":"","Typewords":["{}","Destination"]},{"Name":"SubjectPass","Docs":"","Typewords":["SubjectPass"]},{"Name":"QuotaMessageSize","Docs":"","Typewords":["int64"]},{"Name":"RejectsMailbox","Docs":"","Typewords":["string"]},{"Name":"KeepRejects","Docs":"","Typewords":["bool"]},{"Name":"AutomaticJunkFlags","Docs":"","Typewords":["AutomaticJunkFlags"]},{"Name":"JunkFilter","Docs":"","Typewords":["nullable","JunkFilter"]},{"Name":"MaxOutgoingMessagesPerDay","Docs":"","Typewords":["int32"]},{"Name":"MaxFirstTimeRecipientsPerDay","Docs":"","Typewords":["int32"]},{"Name":"NoFirstTimeSenderDelay","Docs":"","Typewords":["bool"]},{"Name":"NoCustomPassword","Docs":"","Typewords":["bool"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"Aliases","Docs":"","Typewords":["[]","AddressAlias"]}]},
-	"OutgoingWebhook": {"Name":"OutgoingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"Events","Docs":"","Typewords":["[]","string"]}]},
-	"IncomingWebhook": {"Name":"IncomingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]}]},
+	"PasskeyGetOptions": {"Name":"PasskeyGetOptions","Docs":"","Fields":[{"Name":"Challenge","Docs":"","Typewords":["nullable","string"]},{"Name":"RPID","Docs":"","Typewords":["string"]},{"Name":"Timeout","Docs":"","Typewords":["int32"]}]},
+	"PasskeyAssertion": {"Name":"PasskeyAssertion","Docs":"","Fields":[{"Name":"CredentialID","Docs":"","Typewords":["nullable","string"]},{"Name":"ClientDataJSON","Docs":"","Typewords":["nullable","string"]},{"Name":"AuthenticatorData","Docs":"","Typewords":["nullable","string"]},{"Name":"Signature","Docs":"","Typewords":["nullable","string"]}]},
+	"PasswordStatus": {"Name":"PasswordStatus","Docs":"","Fields":[{"Name":"Changed","Docs":"","Typewords":["timestamp"]},{"Name":"Expires","Docs":"","Typewords":["timestamp"]},{"Name":"Expired","Docs":"","Typewords":["bool"]},{"Name":"RecoveryCodes","Docs":"","Typewords":["int32"]}]},
+	"TwoFactorStatus": {"Name":"TwoFactorStatus","Docs":"","Fields":[{"Name":"Enabled","Docs":"","Typewords":["bool"]},{"Name":"Required","Docs":"","Typewords":["bool"]},{"Name":"AppPasswords","Docs":"","Typewords":["[]","AppPassword"]}]},
+	"AppPassword": {"Name":"AppPassword","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Protocols","Docs":"","Typewords":["[]","string"]},{"Name":"IPRanges","Docs":"","Typewords":["[]","string"]},{"Name":"LastUsed","Docs":"","Typewords":["timestamp"]},{"Name":"LastIP","Docs":"","Typewords":["string"]}]},
+	"TOTPSetup": {"Name":"TOTPSetup","Docs":"","Fields":[{"Name":"Secret","Docs":"","Typewords":["string"]},{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"QRCodePNG","Docs":"","Typewords":["string"]}]},
+	"Passkey": {"Name":"Passkey","Docs":"","Fields":[{"Name":"CredentialID","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]},{"Name":"SignCount","Docs":"","Typewords":["uint32"]},{"Name":"LastUsed","Docs":"","Typewords":["timestamp"]}]},
+	"PasskeyCreateOptions": {"Name":"PasskeyCreateOptions","Docs":"","Fields":[{"Name":"Challenge","Docs":"","Typewords":["nullable","string"]},{"Name":"RPID","Docs":"","Typewords":["string"]},{"Name":"RPName","Docs":"","Typewords":["string"]},{"Name":"UserID","Docs":"","Typewords":["nullable","string"]},{"Name":"UserName","Docs":"","Typewords":["string"]},{"Name":"Algorithms","Docs":"","Typewords":["[]","int32"]},{"Name":"ExcludeCredentials","Docs":"","Typewords":["[]","nullable","string"]},{"Name":"Timeout","Docs":"","Typewords":["int32"]}]},
+	"Account": {"Name":"Account","Docs":"","Fields":[{"Name":"OutgoingWebhook","Docs":"","Typewords":["nullable","OutgoingWebhook"]},{"Name":"IncomingWebhook","Docs":"","Typewords":["nullable","IncomingWebhook"]},{"Name":"FromIDLoginAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"KeepRetiredMessagePeriod","Docs":"","Typewords":["int64"]},{"Name":"KeepRetiredWebhookPeriod","Docs":"","Typewords":["int64"]},{"Name":"LoginDisabled","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]},{"Name":"Destinations","Docs":"","Typewords":["{}","Destination"]},{"Name":"SubjectPass","Docs":"","Typewords":["SubjectPass"]},{"Name":"QuotaMessageSize","Docs":"","Typewords":["int64"]},{"Name":"RejectsMailbox","Docs":"","Typewords":["string"]},{"Name":"KeepRejects","Docs":"","Typewords":["bool"]},{"Name":"AutomaticJunkFlags","Docs":"","Typewords":["AutomaticJunkFlags"]},{"Name":"JunkFilter","Docs":"","Typewords":["nullable","JunkFilter"]},{"Name":"JunkReevaluation","Docs":"","Typewords":["nullable","JunkReevaluation"]},{"Name":"Screening","Docs":"","Typewords":["nullable","Screening"]},{"Name":"Categories","Docs":"","Typewords":["nullable","Categories"]},{"Name":"MaxOutgoingMessagesPerDay","Docs":"","Typewords":["int32"]},{"Name":"MaxFirstTimeRecipientsPerDay","Docs":"","Typewords":["int32"]},{"Name":"NoFirstTimeSenderDelay","Docs":"","Typewords":["bool"]},{"Name":"NoCustomPassword","Docs":"","Typewords":["bool"]},{"Name":"PasswordPolicy","Docs":"","Typewords":["nullable","PasswordPolicy"]},{"Name":"RequireTwoFactor","Docs":"","Typewords":["bool"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"OutgoingHeaderRules","Docs":"","Typewords":["nullable","OutgoingHeaderRules"]},{"Name":"OutgoingFooter","Docs":"","Typewords":["nullable","OutgoingFooter"]},{"Name":"Identities","Docs":"","Typewords":["[]","Identity"]},{"Name":"Delegations","Docs":"","Typewords":["[]","Delegation"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"Aliases","Docs":"","Typewords":["[]","AddressAlias"]},{"Name":"OwnedAliases","Docs":"","Typewords":["[]","string"]}]},
+	"OutgoingWebhook": {"Name":"OutgoingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"Events","Docs":"","Typewords":["[]","string"]},{"Name":"SigningKey","Docs":"","Typewords":["string"]},{"Name":"Transcript","Docs":"","Typewords":["bool"]}]},
+	"IncomingWebhook": {"Name":"IncomingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"SigningKey","Docs":"","Typewords":["string"]}]},
 	"Destination": {"Name":"Destination","Docs":"","Fields":[{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Rulesets","Docs":"","Typewords":["[]","Ruleset"]},{"Name":"SMTPError","Docs":"","Typewords":["string"]},{"Name":"MessageAuthRequiredSMTPError","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]}]},
 	"Ruleset": {"Name":"Ruleset","Docs":"","Fields":[{"Name":"SMTPMailFromRegexp","Docs":"","Typewords":["string"]},{"Name":"MsgFromRegexp","Docs":"","Typewords":["string"]},{"Name":"VerifiedDomain","Docs":"","Typewords":["string"]},{"Name":"HeadersRegexp","Docs":"","Typewords":["{}","string"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"ListAllowDomain","Docs":"","Typewords":["string"]},{"Name":"AcceptRejectsToMailbox","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Comment","Docs":"","Typewords":["string"]},{"Name":"VerifiedDNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"ListAllowDNSDomain","Docs":"","Typewords":["Domain"]}]},
 	"Domain": {"Name":"Domain","Docs":"","Fields":[{"Name":"ASCII","Docs":"","Typewords":["string"]},{"Name":"Unicode","Docs":"","Typewords":["string"]}]},
 	"SubjectPass": {"Name":"SubjectPass","Docs":"","Fields":[{"Name":"Period","Docs":"","Typewords":["int64"]}]},
 	"AutomaticJunkFlags": {"Name":"AutomaticJunkFlags","Docs":"","Fields":[{"Name":"Enabled","Docs":"","Typewords":["bool"]},{"Name":"JunkMailboxRegexp","Docs":"","Typewords":["string"]},{"Name":"NeutralMailboxRegexp","Docs":"","Typewords":["string"]},{"Name":"NotJunkMailboxRegexp","Docs":"","Typewords":["string"]}]},
-	"JunkFilter": {"Name":"JunkFilter","Docs":"","Fields":[{"Name":"Threshold","Docs":"","Typewords":["float64"]},{"Name":"Onegrams","Docs":"","Typewords":["bool"]},{"Name":"Twograms","Docs":"","Typewords":["bool"]},{"Name":"Threegrams","Docs":"","Typewords":["bool"]},{"Name":"MaxPower","Docs":"","Typewords":["float64"]},{"Name":"TopWords","Docs":"","Typewords":["int32"]},{"Name":"IgnoreWords","Docs":"","Typewords":["float64"]},{"Name":"RareWords","Docs":"","Typewords":["int32"]}]},
+	"JunkFilter": {"Name":"JunkFilter","Docs":"","Fields":[{"Name":"Threshold","Docs":"","Typewords":["float64"]},{"Name":"TagThreshold","Docs":"","Typewords":["float64"]},{"Name":"JunkThreshold","Docs":"","Typewords":["float64"]},{"Name":"HighAction","Docs":"","Typewords":["string"]},{"Name":"Onegrams","Docs":"","Typewords":["bool"]},{"Name":"Twograms","Docs":"","Typewords":["bool"]},{"Name":"Threegrams","Docs":"","Typewords":["bool"]},{"Name":"MaxPower","Docs":"","Typewords":["float64"]},{"Name":"TopWords","Docs":"","Typewords":["int32"]},{"Name":"IgnoreWords","Docs":"","Typewords":["float64"]},{"Name":"RareWords","Docs":"","Typewords":["int32"]}]},
+	"JunkReevaluation": {"Name":"JunkReevaluation","Docs":"","Fields":[{"Name":"Window","Docs":"","Typewords":["int64"]},{"Name":"Sender","Docs":"","Typewords":["bool"]},{"Name":"DNSBL","Docs":"","Typewords":["bool"]},{"Name":"Spamtrap","Docs":"","Typewords":["bool"]},{"Name":"Notify","Docs":"","Typewords":["bool"]}]},
+	"Screening": {"Name":"Screening","Docs":"","Fields":[{"Name":"Mailbox","Docs":"","Typewords":["string"]}]},
+	"Categories": {"Name":"Categories","Docs":"","Fields":[{"Name":"Newsletters","Docs":"","Typewords":["nullable","Category"]},{"Name":"Notifications","Docs":"","Typewords":["nullable","Category"]},{"Name":"Social","Docs":"","Typewords":["nullable","Category"]}]},
+	"Category": {"Name":"Category","Docs":"","Fields":[{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Domains","Docs":"","Typewords":["[]","string"]}]},
+	"PasswordPolicy": {"Name":"PasswordPolicy","Docs":"","Fields":[{"Name":"MinLength","Docs":"","Typewords":["int32"]},{"Name":"MinEntropy","Docs":"","Typewords":["int32"]},{"Name":"MaxAge","Docs":"","Typewords":["int64"]},{"Name":"LockoutFailures","Docs":"","Typewords":["int32"]},{"Name":"LockoutPeriod","Docs":"","Typewords":["int64"]}]},
 	"Route": {"Name":"Route","Docs":"","Fields":[{"Name":"FromDomain","Docs":"","Typewords":["[]","string"]},{"Name":"ToDomain","Docs":"","Typewords":["[]","string"]},{"Name":"MinimumAttempts","Docs":"","Typewords":["int32"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"FromDomainASCII","Docs":"","Typewords":["[]","string"]},{"Name":"ToDomainASCII","Docs":"","Typewords":["[]","string"]}]},
-	"AddressAlias": {"Name":"AddressAlias","Docs":"","Fields":[{"Name":"SubscriptionAddress","Docs":"","Typewords":["string"]},{"Name":"Alias","Docs":"","Typewords":["Alias"]},{"Name":"MemberAddresses","Docs":"","Typewords":["[]","string"]}]},
-	"Alias": {"Name":"Alias","Docs":"","Fields":[{"Name":"Addresses","Docs":"","Typewords":["[]","string"]},{"Name":"PostPublic","Docs":"","Typewords":["bool"]},{"Name":"ListMembers","Docs":"","Typewords":["bool"]},{"Name":"AllowMsgFrom","Docs":"","Typewords":["bool"]},{"Name":"LocalpartStr","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"ParsedAddresses","Docs":"","Typewords":["[]","AliasAddress"]}]},
-	"AliasAddress": {"Name":"AliasAddress","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["Address"]},{"Name":"AccountName","Docs":"","Typewords":["string"]},{"Name":"Destination","Docs":"","Typewords":["Destination"]}]},
+	"OutgoingHeaderRules": {"Name":"OutgoingHeaderRules","Docs":"","Fields":[{"Name":"Remove","Docs":"","Typewords":["[]","string"]},{"Name":"FromDisplayName","Docs":"","Typewords":["string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Add","Docs":"","Typewords":["[]","string"]}]},
+	"OutgoingFooter": {"Name":"OutgoingFooter","Docs":"","Fields":[{"Name":"Text","Docs":"","Typewords":["[]","string"]},{"Name":"HTML","Docs":"","Typewords":["string"]}]},
+	"Identity": {"Name":"Identity","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"DisplayName","Docs":"","Typewords":["string"]},{"Name":"Signature","Docs":"","Typewords":["[]","string"]},{"Name":"SignatureHTML","Docs":"","Typewords":["string"]},{"Name":"ReplyQuoting","Docs":"","Typewords":["string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Default","Docs":"","Typewords":["bool"]}]},
+	"Delegation": {"Name":"Delegation","Docs":"","Fields":[{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailboxes","Docs":"","Typewords":["[]","string"]},{"Name":"Write","Docs":"","Typewords":["bool"]},{"Name":"Send","Docs":"","Typewords":["string"]}]},
+	"AddressAlias": {"Name":"AddressAlias","Docs":"","Fields":[{"Name":"SubscriptionAddress","Docs":"","Typewords":["string"]},{"Name":"Alias","Docs":"","Typewords":["Alias"]},{"Name":"MemberAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"Member","Docs":"","Typewords":["AliasMember"]}]},
+	"Alias": {"Name":"Alias","Docs":"","Fields":[{"Name":"Addresses","Docs":"","Typewords":["[]","string"]},{"Name":"PostPublic","Docs":"","Typewords":["bool"]},{"Name":"ListMembers","Docs":"","Typewords":["bool"]},{"Name":"AllowMsgFrom","Docs":"","Typewords":["bool"]},{"Name":"PostPolicy","Docs":"","Typewords":["string"]},{"Name":"Owners","Docs":"","Typewords":["[]","string"]},{"Name":"ListHeaders","Docs":"","Typewords":["bool"]},{"Name":"Members","Docs":"","Typewords":["{}","AliasMember"]},{"Name":"List","Docs":"","Typewords":["nullable","AliasList"]},{"Name":"LocalpartStr","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"ParsedAddresses","Docs":"","Typewords":["[]","AliasAddress"]},{"Name":"ParsedOwners","Docs":"","Typewords":["[]","AliasAddress"]}]},
+	"AliasMember": {"Name":"AliasMember","Docs":"","Fields":[{"Name":"NoDelivery","Docs":"","Typewords":["bool"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]}]},
+	"AliasList": {"Name":"AliasList","Docs":"","Fields":[{"Name":"Subscribe","Docs":"","Typewords":["bool"]},{"Name":"Archive","Docs":"","Typewords":["bool"]},{"Name":"BounceLimit","Docs":"","Typewords":["int32"]}]},
+	"AliasAddress": {"Name":"AliasAddress","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["Address"]},{"Name":"AccountName","Docs":"","Typewords":["string"]},{"Name":"Destination","Docs":"","Typewords":["Destination"]},{"Name":"Member","Docs":"","Typewords":["AliasMember"]}]},
 	"Address": {"Name":"Address","Docs":"","Fields":[{"Name":"Localpart","Docs":"","Typewords":["Localpart"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]}]},
-	"Suppression": {"Name":"Suppression","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"BaseAddress","Docs":"","Typewords":["string"]},{"Name":"OriginalAddress","Docs":"","Typewords":["string"]},{"Name":"Manual","Docs":"","Typewords":["bool"]},{"Name":"Reason","Docs":"","Typewords":["string"]}]},
+	"Suppression": {"Name":"Suppression","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"BaseAddress","Docs":"","Typewords":["string"]},{"Name":"OriginalAddress","Docs":"","Typewords":["string"]},{"Name":"Manual","Docs":"","Typewords":["bool"]},{"Name":"Reason","Docs":"","Typewords":["string"]},{"Name":"BounceClass","Docs":"","Typewords":["BounceClass"]}]},
+	"OwnedAlias": {"Name":"OwnedAlias","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"PostPolicy","Docs":"","Typewords":["string"]},{"Name":"Members","Docs":"","Typewords":["[]","string"]},{"Name":"Owners","Docs":"","Typewords":["[]","string"]}]},
 	"ImportProgress": {"Name":"ImportProgress","Docs":"","Fields":[{"Name":"Token","Docs":"","Typewords":["string"]}]},
-	"Outgoing": {"Name":"Outgoing","Docs":"","Fields":[{"Name":"Version","Docs":"","Typewords":["int32"]},{"Name":"Event","Docs":"","Typewords":["OutgoingEvent"]},{"Name":"DSN","Docs":"","Typewords":["bool"]},{"Name":"Suppressing","Docs":"","Typewords":["bool"]},{"Name":"QueueMsgID","Docs":"","Typewords":["int64"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"WebhookQueued","Docs":"","Typewords":["timestamp"]},{"Name":"SMTPCode","Docs":"","Typewords":["int32"]},{"Name":"SMTPEnhancedCode","Docs":"","Typewords":["string"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]}]},
+	"Outgoing": {"Name":"Outgoing","Docs":"","Fields":[{"Name":"Version","Docs":"","Typewords":["int32"]},{"Name":"Event","Docs":"","Typewords":["OutgoingEvent"]},{"Name":"DSN","Docs":"","Typewords":["bool"]},{"Name":"Suppressing","Docs":"","Typewords":["bool"]},{"Name":"QueueMsgID","Docs":"","Typewords":["int64"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"WebhookQueued","Docs":"","Typewords":["timestamp"]},{"Name":"SMTPCode","Docs":"","Typewords":["int32"]},{"Name":"SMTPEnhancedCode","Docs":"","Typewords":["string"]},{"Name":"BounceClass","Docs":"","Typewords":["BounceClass"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]},{"Name":"Recipient","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"NextAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"RemoteMTA","Docs":"","Typewords":["string"]},{"Name":"SMTPResponse","Docs":"","Typewords":["[]","string"]},{"Name":"TLS","Docs":"","Typewords":["string"]},{"Name":"MTASTS","Docs":"","Typewords":["bool"]},{"Name":"DANE","Docs":"","Typewords":["bool"]},{"Name":"Transcript","Docs":"","Typewords":["string"]}]},
 	"Incoming": {"Name":"Incoming","Docs":"","Fields":[{"Name":"Version","Docs":"","Typewords":["int32"]},{"Name":"From","Docs":"","Typewords":["[]","NameAddress"]},{"Name":"To","Docs":"","Typewords":["[]","NameAddress"]},{"Name":"CC","Docs":"","Typewords":["[]","NameAddress"]},{"Name":"BCC","Docs":"","Typewords":["[]","NameAddress"]},{"Name":"ReplyTo","Docs":"","Typewords":["[]","NameAddress"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"InReplyTo","Docs":"","Typewords":["string"]},{"Name":"References","Docs":"","Typewords":["[]","string"]},{"Name":"Date","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Text","Docs":"","Typewords":["string"]},{"Name":"HTML","Docs":"","Typewords":["string"]},{"Name":"Structure","Docs":"","Typewords":["Structure"]},{"Name":"Meta","Docs":"","Typewords":["IncomingMeta"]}]},
 	"NameAddress": {"Name":"NameAddress","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]}]},
 	"Structure": {"Name":"Structure","Docs":"","Fields":[{"Name":"ContentType","Docs":"","Typewords":["string"]},{"Name":"ContentTypeParams","Docs":"","Typewords":["{}","string"]},{"Name":"ContentID","Docs":"","Typewords":["string"]},{"Name":"ContentDisposition","Docs":"","Typewords":["string"]},{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"DecodedSize","Docs":"","Typewords":["int64"]},{"Name":"Parts","Docs":"","Typewords":["[]","Structure"]}]},
 	"IncomingMeta": {"Name":"IncomingMeta","Docs":"","Fields":[{"Name":"MsgID","Docs":"","Typewords":["int64"]},{"Name":"MailFrom","Docs":"","Typewords":["string"]},{"Name":"MailFromValidated","Docs":"","Typewords":["bool"]},{"Name":"MsgFromValidated","Docs":"","Typewords":["bool"]},{"Name":"RcptTo","Docs":"","Typewords":["string"]},{"Name":"DKIMVerifiedDomains","Docs":"","Typewords":["[]","string"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"Received","Docs":"","Typewords":["timestamp"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"Automated","Docs":"","Typewords":["bool"]}]},
+	"SenderListEntry": {"Name":"SenderListEntry","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Pattern","Docs":"","Typewords":["string"]},{"Name":"Action","Docs":"","Typewords":["SenderListAction"]}]},
+	"PGPKey": {"Name":"PGPKey","Docs":"","Fields":[{"Name":"Fingerprint","Docs":"","Typewords":["string"]},{"Name":"KeyID","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Addresses","Docs":"","Typewords":["[]","string"]},{"Name":"PublicKey","Docs":"","Typewords":["string"]},{"Name":"Private","Docs":"","Typewords":["bool"]},{"Name":"Publish","Docs":"","Typewords":["bool"]}]},
 	"TLSPublicKey": {"Name":"TLSPublicKey","Docs":"","Fields":[{"Name":"Fingerprint","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Type","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"NoIMAPPreauth","Docs":"","Typewords":["bool"]},{"Name":"CertDER","Docs":"","Typewords":["nullable","string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]}]},
 	"LoginAttempt": {"Name":"LoginAttempt","Docs":"","Fields":[{"Name":"Key","Docs":"","Typewords":["nullable","string"]},{"Name":"Last","Docs":"","Typewords":["timestamp"]},{"Name":"First","Docs":"","Typewords":["timestamp"]},{"Name":"Count","Docs":"","Typewords":["int64"]},{"Name":"AccountName","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"LocalIP","Docs":"","Typewords":["string"]},{"Name":"TLS","Docs":"","Typewords":["string"]},{"Name":"TLSPubKeyFingerprint","Docs":"","Typewords":["string"]},{"Name":"Protocol","Docs":"","Typewords":["string"]},{"Name":"UserAgent","Docs":"","Typewords":["string"]},{"Name":"AuthMech","Docs":"","Typewords":["string"]},{"Name":"Result","Docs":"","Typewords":["AuthResult"]}]},
+	"Session": {"Name":"Session","Docs":"","Fields":[{"Name":"Web","Docs":"","Typewords":["bool"]},{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Protocol","Docs":"","Typewords":["string"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"UserAgent","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]},{"Name":"Started","Docs":"","Typewords":["timestamp"]},{"Name":"LastActivity","Docs":"","Typewords":["timestamp"]},{"Name":"Current","Docs":"","Typewords":["bool"]}]},
 	"CSRFToken": {"Name":"CSRFToken","Docs":"","Values":null},
 	"Localpart": {"Name":"Localpart","Docs":"","Values":null},
+	"BounceClass": {"Name":"BounceClass","Docs":"","Values":[{"Name":"BounceHard","Value":"hard","Docs":""},{"Name":"BounceSoft","Value":"soft","Docs":""},{"Name":"BounceBlock","Value":"block","Docs":""},{"Name":"BouncePolicy","Value":"policy","Docs":""}]},
 	"OutgoingEvent": {"Name":"OutgoingEvent","Docs":"","Values":[{"Name":"EventDelivered","Value":"delivered","Docs":""},{"Name":"EventSuppressed","Value":"suppressed","Docs":""},{"Name":"EventDelayed","Value":"delayed","Docs":""},{"Name":"EventFailed","Value":"failed","Docs":""},{"Name":"EventRelayed","Value":"relayed","Docs":""},{"Name":"EventExpanded","Value":"expanded","Docs":""},{"Name":"EventCanceled","Value":"canceled","Docs":""},{"Name":"EventUnrecognized","Value":"unrecognized","Docs":""}]},
-	"AuthResult": {"Name":"AuthResult","Docs":"","Values":[{"Name":"AuthSuccess","Value":"ok","Docs":""},{"Name":"AuthBadUser","Value":"baduser","Docs":""},{"Name":"AuthBadPassword","Value":"badpassword","Docs":""},{"Name":"AuthBadCredentials","Value":"badcreds","Docs":""},{"Name":"AuthBadChannelBinding","Value":"badchanbind","Docs":""},{"Name":"AuthBadProtocol","Value":"badprotocol","Docs":""},{"Name":"AuthLoginDisabled","Value":"logindisabled","Docs":""},{"Name":"AuthError","Value":"error","Docs":""},{"Name":"AuthAborted","Value":"aborted","Docs":""}]},
+	"SenderListAction": {"Name":"SenderListAction","Docs":"","Values":[{"Name":"SenderListAllow","Value":"allow","Docs":""},{"Name":"SenderListReject","Value":"reject","Docs":""},{"Name":"SenderListDiscard","Value":"discard","Docs":""}]},
+	"AuthResult": {"Name":"AuthResult","Docs":"","Values":[{"Name":"AuthSuccess","Value":"ok","Docs":""},{"Name":"AuthBadUser","Value":"baduser","Docs":""},{"Name":"AuthBadPassword","Value":"badpassword","Docs":""},{"Name":"AuthBadCredentials","Value":"badcreds","Docs":""},{"Name":"AuthBadChannelBinding","Value":"badchanbind","Docs":""},{"Name":"AuthBadProtocol","Value":"badprotocol","Docs":""},{"Name":"AuthLoginDisabled","Value":"logindisabled","Docs":""},{"Name":"AuthLoginLocked","Value":"loginlocked","Docs":""},{"Name":"AuthPasswordExpired","Value":"passwordexpired","Docs":""},{"Name":"AuthAppPasswordRequired","Value":"apppasswordrequired","Docs":""},{"Name":"AuthTOTPRequired","Value":"totprequired","Docs":""},{"Name":"AuthTwoFactorSetup","Value":"twofactorsetup","Docs":""},{"Name":"AuthError","Value":"error","Docs":""},{"Name":"AuthAborted","Value":"aborted","Docs":""}]},
 }
 
 export const parser = {
+	PasskeyGetOptions: (v: any) => parse("PasskeyGetOptions", v) as PasskeyGetOptions,
+	PasskeyAssertion: (v: any) => parse("PasskeyAssertion", v) as PasskeyAssertion,
+	PasswordStatus: (v: any) => parse("PasswordStatus", v) as PasswordStatus,
+	TwoFactorStatus: (v: any) => parse("TwoFactorStatus", v) as TwoFactorStatus,
+	AppPassword: (v: any) => parse("AppPassword", v) as AppPassword,
+	TOTPSetup: (v: any) => parse("TOTPSetup", v) as TOTPSetup,
+	Passkey: (v: any) => parse("Passkey", v) as Passkey,
+	PasskeyCreateOptions: (v: any) => parse("PasskeyCreateOptions", v) as PasskeyCreateOptions,
 	Account: (v: any) => parse("Account", v) as Account,
 	OutgoingWebhook: (v: any) => parse("OutgoingWebhook", v) as OutgoingWebhook,
 	IncomingWebhook: (v: any) => parse("IncomingWebhook", v) as IncomingWebhook,
@@ -335,23 +641,40 @@ export const parser = {
 	SubjectPass: (v: any) => parse("SubjectPass", v) as SubjectPass,
 	AutomaticJunkFlags: (v: any) => parse("AutomaticJunkFlags", v) as AutomaticJunkFlags,
 	JunkFilter: (v: any) => parse("JunkFilter", v) as JunkFilter,
+	JunkReevaluation: (v: any) => parse("JunkReevaluation", v) as JunkReevaluation,
+	Screening: (v: any) => parse("Screening", v) as Screening,
+	Categories: (v: any) => parse("Categories", v) as Categories,
+	Category: (v: any) => parse("Category", v) as Category,
+	PasswordPolicy: (v: any) => parse("PasswordPolicy", v) as PasswordPolicy,
 	Route: (v: any) => parse("Route", v) as Route,
+	OutgoingHeaderRules: (v: any) => parse("OutgoingHeaderRules", v) as OutgoingHeaderRules,
+	OutgoingFooter: (v: any) => parse("OutgoingFooter", v) as OutgoingFooter,
+	Identity: (v: any) => parse("Identity", v) as Identity,
+	Delegation: (v: any) => parse("Delegation", v) as Delegation,
 	AddressAlias: (v: any) => parse("AddressAlias", v) as AddressAlias,
 	Alias: (v: any) => parse("Alias", v) as Alias,
+	AliasMember: (v: any) => parse("AliasMember", v) as AliasMember,
+	AliasList: (v: any) => parse("AliasList", v) as AliasList,
 	AliasAddress: (v: any) => parse("AliasAddress", v) as AliasAddress,
 	Address: (v: any) => parse("Address", v) as Address,
 	Suppression: (v: any) => parse("Suppression", v) as Suppression,
+	OwnedAlias: (v: any) => parse("OwnedAlias", v) as OwnedAlias,
 	ImportProgress: (v: any) => parse("ImportProgress", v) as ImportProgress,
 	Outgoing: (v: any) => parse("Outgoing", v) as Outgoing,
 	Incoming: (v: any) => parse("Incoming", v) as Incoming,
 	NameAddress: (v: any) => parse("NameAddress", v) as NameAddress,
 	Structure: (v: any) => parse("Structure", v) as Structure,
 	IncomingMeta: (v: any) => parse("IncomingMeta", v) as IncomingMeta,
+	SenderListEntry: (v: any) => parse("SenderListEntry", v) as SenderListEntry,
+	PGPKey: (v: any) => parse("PGPKey", v) as PGPKey,
 	TLSPublicKey: (v: any) => parse("TLSPublicKey", v) as TLSPublicKey,
 	LoginAttempt: (v: any) => parse("LoginAttempt", v) as LoginAttempt,
+	Session: (v: any) => parse("Session", v) as Session,
 	CSRFToken: (v: any) => parse("CSRFToken", v) as CSRFToken,
 	Localpart: (v: any) => parse("Localpart", v) as Localpart,
+	BounceClass: (v: any) => parse("BounceClass", v) as BounceClass,
 	OutgoingEvent: (v: any) => parse("OutgoingEvent", v) as OutgoingEvent,
+	SenderListAction: (v: any) => parse("SenderListAction", v) as SenderListAction,
 	AuthResult: (v: any) => parse("AuthResult", v) as AuthResult,
 }
 
@@ -396,15 +719,50 @@ export class Client {
 	}
 
 	// Login returns a session token for the credentials, or fails with error code
-	// "user:badLogin". Call LoginPrep to get a loginToken.
-	async Login(loginToken: string, username: string, password: string): Promise<CSRFToken> {
+	// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+	// authentication is enabled, totp must be a code from the authenticator app,
+	// otherwise the login fails with error code "user:totpRequired".
+	async Login(loginToken: string, username: string, password: string, totp: string): Promise<CSRFToken> {
 		const fn: string = "Login"
-		const paramTypes: string[][] = [["string"],["string"],["string"]]
+		const paramTypes: string[][] = [["string"],["string"],["string"],["string"]]
 		const returnTypes: string[][] = [["CSRFToken"]]
-		const params: any[] = [loginToken, username, password]
+		const params: any[] = [loginToken, username, password, totp]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CSRFToken
 	}
 
+	// PasskeyLoginStart returns the parameters for the browser to login with a
+	// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+	// of the browser.
+	async PasskeyLoginStart(loginToken: string): Promise<PasskeyGetOptions> {
+		const fn: string = "PasskeyLoginStart"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["PasskeyGetOptions"]]
+		const params: any[] = [loginToken]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PasskeyGetOptions
+	}
+
+	// PasskeyLogin returns a session token for a passkey login started with
+	// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+	// password, the passkey must verify the user, e.g. with a PIN. With username and
+	// password, the passkey is the second factor.
+	async PasskeyLogin(loginToken: string, username: string, password: string, assertion: PasskeyAssertion): Promise<CSRFToken> {
+		const fn: string = "PasskeyLogin"
+		const paramTypes: string[][] = [["string"],["string"],["string"],["PasskeyAssertion"]]
+		const returnTypes: string[][] = [["CSRFToken"]]
+		const params: any[] = [loginToken, username, password, assertion]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CSRFToken
+	}
+
+	// OIDCEnabled returns whether logging in through an OpenID Connect identity
+	// provider is possible, by sending the browser to "oidc/login".
+	async OIDCEnabled(): Promise<boolean> {
+		const fn: string = "OIDCEnabled"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["bool"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as boolean
+	}
+
 	// Logout invalidates the session token.
 	async Logout(): Promise<void> {
 		const fn: string = "Logout"
@@ -429,7 +787,8 @@ export class Client {
 	// Sessions are not interrupted, and will keep working. New login attempts must use
 	// the new password.
 	// 
-	// Password must be at least 8 characters.
+	// Password must be at least 8 characters, and meet the password policy of the
+	// account, if any.
 	// 
 	// Setting a user-supplied password is not allowed if NoCustomPassword is set
 	// for the account.
@@ -451,15 +810,141 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
 	}
 
+	// PasswordStatus returns the state of the password, e.g. whether it has expired,
+	// and the number of unused recovery codes.
+	async PasswordStatus(): Promise<PasswordStatus> {
+		const fn: string = "PasswordStatus"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["PasswordStatus"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PasswordStatus
+	}
+
+	// RecoveryCodesGenerate replaces any existing recovery codes with new codes and
+	// returns them. Each code can be used once instead of the password to login to the
+	// account web interface, after which a new password must be set.
+	async RecoveryCodesGenerate(): Promise<string[] | null> {
+		const fn: string = "RecoveryCodesGenerate"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","string"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string[] | null
+	}
+
+	// TwoFactorStatus returns whether two-factor authentication is enabled or
+	// required, and the app passwords.
+	async TwoFactorStatus(): Promise<TwoFactorStatus> {
+		const fn: string = "TwoFactorStatus"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["TwoFactorStatus"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as TwoFactorStatus
+	}
+
+	// TOTPSetupStart starts enabling two-factor authentication with a time-based
+	// one-time password (TOTP), returning the parameters for the authenticator app.
+	// Call TOTPSetupConfirm with a code from the app to enable it.
+	async TOTPSetupStart(): Promise<TOTPSetup> {
+		const fn: string = "TOTPSetupStart"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["TOTPSetup"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as TOTPSetup
+	}
+
+	// TOTPSetupConfirm enables two-factor authentication after verifying a code
+	// from the authenticator app. Logins to the web interfaces then require a code,
+	// and other protocols, like IMAP and SMTP, require app passwords.
+	async TOTPSetupConfirm(code: string): Promise<void> {
+		const fn: string = "TOTPSetupConfirm"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [code]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// TOTPRemove disables two-factor authentication, after verifying a current code.
+	// Not possible when the admin requires two-factor authentication.
+	async TOTPRemove(code: string): Promise<void> {
+		const fn: string = "TOTPRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [code]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AppPasswordAdd adds an app password for use by a single application, e.g. an
+	// IMAP/SMTP client, for the given protocols: "imap", "submission", "webapi",
+	// "caldav", "carddav". If ipRanges is non-empty, the password can only be used
+	// from those networks, in CIDR notation or single IPs. The generated password is
+	// returned, it cannot be retrieved later.
+	async AppPasswordAdd(name: string, protocols: string[] | null, ipRanges: string[] | null): Promise<string> {
+		const fn: string = "AppPasswordAdd"
+		const paramTypes: string[][] = [["string"],["[]","string"],["[]","string"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [name, protocols, ipRanges]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// AppPasswordRemove removes an app password.
+	async AppPasswordRemove(id: number): Promise<void> {
+		const fn: string = "AppPasswordRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [id]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// Passkeys returns the passkeys registered for the account.
+	async Passkeys(): Promise<Passkey[] | null> {
+		const fn: string = "Passkeys"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Passkey"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Passkey[] | null
+	}
+
+	// PasskeyRegisterStart starts registration of a new passkey, returning the
+	// parameters for the browser. Complete the registration with PasskeyRegister.
+	async PasskeyRegisterStart(): Promise<PasskeyCreateOptions> {
+		const fn: string = "PasskeyRegisterStart"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["PasskeyCreateOptions"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PasskeyCreateOptions
+	}
+
+	// PasskeyRegister completes registration of a passkey with the response from the
+	// browser. Name describes the passkey, e.g. the device. Logging in with the
+	// passkey starts a session for the current login address.
+	async PasskeyRegister(name: string, clientDataJSON: string | null, attestationObject: string | null): Promise<Passkey> {
+		const fn: string = "PasskeyRegister"
+		const paramTypes: string[][] = [["string"],["nullable","string"],["nullable","string"]]
+		const returnTypes: string[][] = [["Passkey"]]
+		const params: any[] = [name, clientDataJSON, attestationObject]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Passkey
+	}
+
+	// PasskeyRemove removes a passkey.
+	async PasskeyRemove(credentialID: string): Promise<void> {
+		const fn: string = "PasskeyRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [credentialID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// Account returns information about the account.
 	// StorageUsed is the sum of the sizes of all messages, in bytes.
 	// StorageLimit is the maximum storage that can be used, or 0 if there is no limit.
-	async Account(): Promise<[Account, number, number, Suppression[] | null]> {
+	// DisabledFeatures are features of the interface disabled through the web config
+	// of the domain of the account, see config.WebFeatures.
+	async Account(): Promise<[Account, number, number, Suppression[] | null, string[] | null]> {
 		const fn: string = "Account"
 		const paramTypes: string[][] = []
-		const returnTypes: string[][] = [["Account"],["int64"],["int64"],["[]","Suppression"]]
+		const returnTypes: string[][] = [["Account"],["int64"],["int64"],["[]","Suppression"],["[]","string"]]
 		const params: any[] = []
-		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [Account, number, number, Suppression[] | null]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [Account, number, number, Suppression[] | null, string[] | null]
 	}
 
 	// AccountSaveFullName saves the full name (used as display name in email messages)
@@ -483,6 +968,54 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// AliasMemberSave saves the delivery preferences of an address of the account
+	// that is a member of the alias.
+	async AliasMemberSave(aliasAddress: string, memberAddress: string, member: AliasMember): Promise<void> {
+		const fn: string = "AliasMemberSave"
+		const paramTypes: string[][] = [["string"],["string"],["AliasMember"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliasAddress, memberAddress, member]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AliasLeave removes an address of the account from the members of the alias.
+	async AliasLeave(aliasAddress: string, memberAddress: string): Promise<void> {
+		const fn: string = "AliasLeave"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliasAddress, memberAddress]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AliasesOwned returns the aliases that have an address of the account as owner.
+	async AliasesOwned(): Promise<OwnedAlias[] | null> {
+		const fn: string = "AliasesOwned"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","OwnedAlias"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as OwnedAlias[] | null
+	}
+
+	// AliasOwnerMembersAdd adds member addresses to an alias owned by the account.
+	// Members must be addresses of local accounts.
+	async AliasOwnerMembersAdd(aliasAddress: string, addresses: string[] | null): Promise<void> {
+		const fn: string = "AliasOwnerMembersAdd"
+		const paramTypes: string[][] = [["string"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliasAddress, addresses]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AliasOwnerMembersRemove removes member addresses from an alias owned by the
+	// account.
+	async AliasOwnerMembersRemove(aliasAddress: string, addresses: string[] | null): Promise<void> {
+		const fn: string = "AliasOwnerMembersRemove"
+		const paramTypes: string[][] = [["string"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliasAddress, addresses]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// ImportAbort aborts an import that is in progress. If the import exists and isn't
 	// finished, no changes will have been made by the import.
 	async ImportAbort(importToken: string): Promise<void> {
@@ -622,6 +1155,111 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// ScreeningSave saves the screening settings for first-time senders. A nil value
+	// disables screening.
+	async ScreeningSave(screening: Screening | null): Promise<void> {
+		const fn: string = "ScreeningSave"
+		const paramTypes: string[][] = [["nullable","Screening"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [screening]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// CategoriesSave saves the settings for categorizing incoming bulk messages into
+	// newsletters, notifications and social updates. A nil value disables
+	// categorization, a nil category disables that category.
+	async CategoriesSave(categories: Categories | null): Promise<void> {
+		const fn: string = "CategoriesSave"
+		const paramTypes: string[][] = [["nullable","Categories"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [categories]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// SenderList returns the entries of the sender allow and block lists.
+	async SenderList(): Promise<SenderListEntry[] | null> {
+		const fn: string = "SenderList"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","SenderListEntry"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SenderListEntry[] | null
+	}
+
+	// SenderListAdd adds an address ("user@example.org"), domain ("example.org") or
+	// wildcard domain ("*.example.org", also matching example.org) to the sender
+	// lists, with action "allow" (accept without junk filtering if the message From
+	// address is verified), "reject" (reject during SMTP) or "discard" (accept
+	// during SMTP, but don't deliver). For a pattern already present, the action is
+	// changed.
+	async SenderListAdd(pattern: string, action: SenderListAction): Promise<SenderListEntry> {
+		const fn: string = "SenderListAdd"
+		const paramTypes: string[][] = [["string"],["SenderListAction"]]
+		const returnTypes: string[][] = [["SenderListEntry"]]
+		const params: any[] = [pattern, action]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SenderListEntry
+	}
+
+	// SenderListRemove removes an entry from the sender lists.
+	async SenderListRemove(id: number): Promise<void> {
+		const fn: string = "SenderListRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [id]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// IdentitiesSave saves the identities for sending messages with webmail. Each
+	// identity address must be allowed as message From address for the account.
+	async IdentitiesSave(identities: Identity[] | null): Promise<void> {
+		const fn: string = "IdentitiesSave"
+		const paramTypes: string[][] = [["[]","Identity"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [identities]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// PGPKeys returns the OpenPGP keys of the account.
+	async PGPKeys(): Promise<PGPKey[] | null> {
+		const fn: string = "PGPKeys"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","PGPKey"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PGPKey[] | null
+	}
+
+	// PGPKeyAdd adds an armored OpenPGP key to the account. A public key is used for
+	// encrypting messages to, and verifying messages from, the addresses of the key.
+	// A private key, typically encrypted with a passphrase, is used for signing and
+	// decrypting messages. A private key can be added for a public key that is
+	// already present. Keys with EdDSA or Curve25519 algorithms are not supported.
+	async PGPKeyAdd(armored: string): Promise<PGPKey> {
+		const fn: string = "PGPKeyAdd"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["PGPKey"]]
+		const params: any[] = [armored]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PGPKey
+	}
+
+	// PGPKeyPublish changes whether a key is served in the OpenPGP Web Key Directory,
+	// for the addresses of the key that belong to the account. Only keys with a
+	// private key can be published.
+	async PGPKeyPublish(fingerprint: string, publish: boolean): Promise<void> {
+		const fn: string = "PGPKeyPublish"
+		const paramTypes: string[][] = [["string"],["bool"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [fingerprint, publish]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// PGPKeyRemove removes a key from the account.
+	async PGPKeyRemove(fingerprint: string): Promise<void> {
+		const fn: string = "PGPKeyRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [fingerprint]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	async TLSPublicKeys(): Promise<TLSPublicKey[] | null> {
 		const fn: string = "TLSPublicKeys"
 		const paramTypes: string[][] = []
@@ -661,6 +1299,37 @@ export class Client {
 		const params: any[] = [limit]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as LoginAttempt[] | null
 	}
+
+	// Sessions returns the active web sessions and IMAP/submission connections of the
+	// account.
+	async Sessions(): Promise<Session[] | null> {
+		const fn: string = "Sessions"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Session"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Session[] | null
+	}
+
+	// SessionRevoke ends a session. A web session is logged out, a connection is
+	// closed. Clients with a stored password can log in again, change the password to
+	// prevent that.
+	async SessionRevoke(web: boolean, id: number): Promise<void> {
+		const fn: string = "SessionRevoke"
+		const paramTypes: string[][] = [["bool"],["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [web, id]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// SessionsRevokeAll ends all sessions except the session of this request, and
+	// returns the number of sessions ended.
+	async SessionsRevokeAll(): Promise<number> {
+		const fn: string = "SessionsRevokeAll"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
 }
 
 export const defaultBaseURL = (function() {
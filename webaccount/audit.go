@@ -0,0 +1,33 @@
+package webaccount
+
+import (
+	"github.com/mjl-/mox/store"
+)
+
+// API methods recorded in the audit log, with the kind of event. Other methods
+// only read, or change settings of less interest, like junk filtering.
+var auditMethods = map[string]string{
+	"SetPassword":           store.AuditPassword,
+	"RecoveryCodesGenerate": store.AuditPassword,
+	"TOTPSetupConfirm":      store.AuditPassword,
+	"TOTPRemove":            store.AuditPassword,
+	"AppPasswordAdd":        store.AuditPassword,
+	"AppPasswordRemove":     store.AuditPassword,
+	"PasskeyRegister":       store.AuditPassword,
+	"PasskeyRemove":         store.AuditPassword,
+	"PGPKeyAdd":             store.AuditPassword,
+	"PGPKeyRemove":          store.AuditPassword,
+	"TLSPublicKeyAdd":       store.AuditPassword,
+	"TLSPublicKeyRemove":    store.AuditPassword,
+	"TLSPublicKeyUpdate":    store.AuditPassword,
+	"SessionRevoke":         store.AuditPassword,
+	"SessionsRevokeAll":     store.AuditPassword,
+
+	"DestinationSave":          store.AuditSettings,
+	"OutgoingWebhookSave":      store.AuditSettings,
+	"IncomingWebhookSave":      store.AuditSettings,
+	"FromIDLoginAddressesSave": store.AuditSettings,
+	"IdentitiesSave":           store.AuditSettings,
+	"SuppressionAdd":           store.AuditSettings,
+	"SuppressionRemove":        store.AuditSettings,
+}
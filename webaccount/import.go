@@ -423,7 +423,7 @@ func importMessages(ctx context.Context, log mlog.Log, token string, acc *store.
 			err := f.Close()
 			log.Check(err, "closing file after training junkfilter")
 		}()
-		p, err := m.LoadPart(f)
+		p, err := m.LoadPart(store.MessageFileReader(*m, f))
 		if err != nil {
 			problemf("loading parsed message again for training junk filter: %v (continuing)", err)
 			return
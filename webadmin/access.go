@@ -0,0 +1,352 @@
+package webadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/sherpa"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+)
+
+// Access to API methods for admin users with limited roles. Logins with the admin
+// password and admin users with role "admin" can call all methods.
+
+type paramKind int
+
+const (
+	paramDomain  paramKind = iota // Domain name.
+	paramAccount                  // Account name, matched by the default domain of the account.
+	paramAddress                  // Email address, or "@domain" for catchall.
+)
+
+// param is a parameter of an API call that must refer to one of the domains of a
+// domain admin.
+type param struct {
+	kind  paramKind
+	index int
+}
+
+type methodAccess struct {
+	// Allowed for all admin users, e.g. for logout.
+	all bool
+
+	// Only allowed for logins with the admin password, e.g. for managing its
+	// two-factor authentication.
+	adminPassword bool
+
+	// Method does not make changes or expose secrets, allowed for auditors.
+	read bool
+
+	// For domain admins. If set, the method filters its results by the domains of
+	// the domain admin.
+	filtered bool
+
+	// For domain admins. If non-empty, the method is allowed if all params refer to
+	// the domains of the domain admin.
+	params []param
+}
+
+func domainParam(index int) []param {
+	return []param{{paramDomain, index}}
+}
+
+func accountParam(index int) []param {
+	return []param{{paramAccount, index}}
+}
+
+// Methods not listed are only allowed for full admins.
+var apiAccess = map[string]methodAccess{
+	"Logout":              {all: true},
+	"Version":             {all: true},
+	"AdminSelf":           {all: true},
+	"CheckUpdatesEnabled": {all: true},
+	"ParseDomain":         {all: true},
+
+	"AdminTOTPEnabled":          {adminPassword: true},
+	"AdminTOTPSetupStart":       {adminPassword: true},
+	"AdminTOTPSetupConfirm":     {adminPassword: true},
+	"AdminTOTPRemove":           {adminPassword: true},
+	"AdminPasskeys":             {adminPassword: true},
+	"AdminPasskeyRegisterStart": {adminPassword: true},
+	"AdminPasskeyRegister":      {adminPassword: true},
+	"AdminPasskeyRemove":        {adminPassword: true},
+
+	"Domains":      {read: true, filtered: true},
+	"Accounts":     {read: true, filtered: true},
+	"StorageUsage": {read: true, filtered: true},
+
+	"CheckDomain":            {read: true, params: domainParam(0)},
+	"DomainReadiness":        {read: true, params: domainParam(0)},
+	"Domain":                 {read: true, params: domainParam(0)},
+	"DomainConfig":           {read: true, params: domainParam(0)},
+	"DomainLocalparts":       {read: true, params: domainParam(0)},
+	"DomainRecords":          {read: true, params: domainParam(0)},
+	"ClientConfigsDomain":    {read: true, params: domainParam(0)},
+	"TLSReports":             {read: true, params: domainParam(2)},
+	"TLSReportID":            {read: true, params: domainParam(0)},
+	"TLSRPTSummaries":        {read: true, params: domainParam(2)},
+	"TLSRPTStats":            {read: true, params: domainParam(2)},
+	"TLSRPTFailureTypes":     {read: true, params: domainParam(0)},
+	"DMARCReports":           {read: true, params: domainParam(2)},
+	"DMARCReportID":          {read: true, params: domainParam(0)},
+	"DMARCFailureReports":    {read: true, params: domainParam(2)},
+	"DMARCFailureReportID":   {read: true, params: domainParam(0)},
+	"DMARCSummaries":         {read: true, params: domainParam(2)},
+	"DMARCAnalytics":         {read: true, params: domainParam(2)},
+	"DMARCEvaluationsDomain": {read: true, params: domainParam(0)},
+	"TLSRPTResultsDomain":    {read: true, params: domainParam(1)},
+	"LookupTLSRPTRecord":     {read: true, params: domainParam(0)},
+	"DomainMTASTSRollout":    {read: true, params: domainParam(0)},
+	"DomainDKIMRotation":     {read: true, params: domainParam(0)},
+
+	"Account":                 {read: true, params: accountParam(0)},
+	"AccountTwoFactorEnabled": {read: true, params: accountParam(0)},
+	"AccountPasskeys":         {read: true, params: accountParam(0)},
+	"TLSPublicKeys":           {read: true, params: accountParam(0)},
+	"LoginAttempts":           {read: true, params: accountParam(0)},
+	"AccountJunkFilterStats":  {read: true, params: accountParam(0)},
+	"AccountJunkVerdicts":     {read: true, params: accountParam(0)},
+
+	// Global information, not for domain admins. Webhook queues are not included, they
+	// hold authorization headers.
+	"MTASTSPolicies":       {read: true},
+	"Postmaster":           {read: true},
+	"SenderReputations":    {read: true},
+	"LookupIP":             {read: true},
+	"SPFAnalyze":           {read: true},
+	"DANEHostStatus":       {read: true},
+	"DNSBLStatus":          {read: true},
+	"QueueSize":            {read: true},
+	"QueueHoldRuleList":    {read: true},
+	"QueueList":            {read: true},
+	"QueueCount":           {read: true},
+	"QueueMsgTranscript":   {read: true},
+	"RetiredList":          {read: true},
+	"QueueDeadList":        {read: true},
+	"QueueHostHealthList":  {read: true},
+	"HookQueueSize":        {read: true},
+	"LogLevels":            {read: true},
+	"WebserverConfig":      {read: true},
+	"DMARCEvaluationStats": {read: true},
+	"DMARCSuppressList":    {read: true},
+	"TLSRPTResults":        {read: true},
+	"TLSRPTSuppressList":   {read: true},
+	"LookupCid":            {read: true},
+	"AuditList":            {read: true},
+	"SignupRequests":       {read: true},
+	"SignupInvites":        {read: true},
+
+	// Not listed for auditors: Config and ConfigDynamic*, the dynamic config has
+	// secrets like webhook authorization headers and signing keys. Account redacts
+	// them for auditors.
+
+	"DMARCRemoveEvaluations":         {params: domainParam(0)},
+	"TLSRPTRemoveResults":            {params: domainParam(1)},
+	"DomainRequireTwoFactorSave":     {params: domainParam(0)},
+	"DomainOutgoingFooterSave":       {params: domainParam(0)},
+	"DomainSpamActionsSave":          {params: domainParam(0)},
+	"DomainMessageTemplatesSave":     {params: domainParam(0)},
+	"DomainDescriptionSave":          {params: domainParam(0)},
+	"DomainSignupSave":               {params: domainParam(0)},
+	"DomainClientSettingsDomainSave": {params: domainParam(0)},
+	"DomainLocalpartConfigSave":      {params: domainParam(0)},
+	"DomainMTASTSSave":               {params: domainParam(0)},
+	"DomainMTASTSPolicySave":         {params: domainParam(0)},
+	"DomainMTASTSPromote":            {params: domainParam(0)},
+	"DomainDKIMRecordsRemoved":       {params: domainParam(0)},
+	"DomainDKIMAdd":                  {params: domainParam(0)},
+	"DomainDKIMRemove":               {params: domainParam(0)},
+	"DomainDKIMSave":                 {params: domainParam(0)},
+	"AliasAdd":                       {params: domainParam(1)},
+	"AliasUpdate":                    {params: domainParam(1)},
+	"AliasRemove":                    {params: domainParam(1)},
+	"AliasAddressesAdd":              {params: domainParam(1)},
+	"AliasAddressesRemove":           {params: domainParam(1)},
+	"AliasListSave":                  {params: domainParam(1)},
+	"AliasMailingListSave":           {params: domainParam(1)},
+	"ListSubscribers":                {params: domainParam(1)},
+	"ListSubscriberAdd":              {params: domainParam(1)},
+	"ListSubscriberRemove":           {params: domainParam(1)},
+	"ListArchive":                    {params: domainParam(1)},
+	"ListArchiveMessage":             {params: domainParam(1)},
+
+	"AccountAdd":                  {params: []param{{paramAddress, 1}}},
+	"AccountRemove":               {params: accountParam(0)},
+	"AddressAdd":                  {params: []param{{paramAddress, 0}, {paramAccount, 1}}},
+	"AddressRemove":               {params: []param{{paramAddress, 0}}},
+	"SetPassword":                 {params: accountParam(0)},
+	"PasswordRequireChange":       {params: accountParam(0)},
+	"AccountPasswordPolicySave":   {params: accountParam(0)},
+	"AccountRequireTwoFactorSave": {params: accountParam(0)},
+	"AccountTOTPRemove":           {params: accountParam(0)},
+	"AccountPasskeyRemove":        {params: accountParam(0)},
+	"AccountSettingsSave":         {params: accountParam(0)},
+	"AccountLoginDisabledSave":    {params: accountParam(0)},
+	"AccountOutgoingFooterSave":   {params: accountParam(0)},
+	"AccountSpamActionsSave":      {params: accountParam(0)},
+	"AccountImpersonate":          {params: accountParam(0)},
+	"AccountJunkFilterRetrain":    {params: accountParam(0)},
+}
+
+var errAccess = errors.New("not allowed for admin user")
+
+// checkAccess checks if admin user au may call method with params, the raw JSON
+// parameters of the sherpa call.
+func checkAccess(au config.AdminUser, method string, params []json.RawMessage) error {
+	ma := apiAccess[method]
+	if ma.all {
+		return nil
+	} else if ma.adminPassword {
+		return fmt.Errorf("%w: only for login with admin password", errAccess)
+	} else if au.Role == config.AdminRoleAdmin {
+		return nil
+	}
+
+	switch au.Role {
+	case config.AdminRoleAuditor:
+		if !ma.read {
+			return fmt.Errorf("%w: role %s has read-only access", errAccess, au.Role)
+		}
+		return nil
+
+	case config.AdminRoleDomainAdmin:
+		if ma.filtered {
+			return nil
+		} else if len(ma.params) == 0 {
+			return fmt.Errorf("%w: role %s only has access to its domains", errAccess, au.Role)
+		}
+		for _, p := range ma.params {
+			var s string
+			if p.index >= len(params) {
+				return fmt.Errorf("%w: missing parameter", errAccess)
+			} else if err := json.Unmarshal(params[p.index], &s); err != nil {
+				return fmt.Errorf("%w: parameter %d is not a string", errAccess, p.index)
+			}
+			d, err := paramDomainParse(p.kind, s)
+			if err != nil {
+				return fmt.Errorf("%w: %v", errAccess, err)
+			}
+			if !slices.Contains(au.DNSDomains, d) {
+				return fmt.Errorf("%w: no access to domain %s", errAccess, d)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unknown role %q", errAccess, au.Role)
+}
+
+// paramDomainParse returns the domain a parameter refers to.
+func paramDomainParse(kind paramKind, s string) (dns.Domain, error) {
+	switch kind {
+	case paramDomain:
+		return dns.ParseDomain(s)
+	case paramAccount:
+		ac, ok := mox.Conf.Account(s)
+		if !ok {
+			return dns.Domain{}, fmt.Errorf("unknown account %q", s)
+		}
+		return ac.DNSDomain, nil
+	case paramAddress:
+		i := strings.LastIndex(s, "@")
+		if i < 0 {
+			return dns.Domain{}, fmt.Errorf("missing domain in address %q", s)
+		}
+		return dns.ParseDomain(s[i+1:])
+	}
+	return dns.Domain{}, fmt.Errorf("unknown parameter kind")
+}
+
+// apiAccessCheck checks whether admin user au may make the API call in r, and
+// writes an error response if not. The request body is read and replaced, for
+// the sherpa handler.
+func apiAccessCheck(au config.AdminUser, w http.ResponseWriter, r *http.Request) bool {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+
+	var err error
+	if !apiAccess[method].all {
+		var buf []byte
+		buf, err = io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
+		r.Body = io.NopCloser(bytes.NewReader(buf))
+		if err == nil {
+			var req struct {
+				Params []json.RawMessage `json:"params"`
+			}
+			err = json.Unmarshal(buf, &req)
+			if err == nil {
+				err = checkAccess(au, method, req.Params)
+			}
+		}
+	}
+	if err == nil {
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	result := struct {
+		Error sherpa.Error `json:"error"`
+	}{
+		sherpa.Error{Code: "user:forbidden", Message: err.Error()},
+	}
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		pkglog.Errorx("writing api access error response", err)
+	}
+	return false
+}
+
+// xadminUser returns the admin user making the API call. For logins with the admin
+// password, the user has role "admin".
+func xadminUser(ctx context.Context) (name string, au config.AdminUser) {
+	reqInfo, ok := ctx.Value(requestInfoCtxKey).(requestInfo)
+	if !ok || reqInfo.AdminUser == "" {
+		return "", config.AdminUser{Role: config.AdminRoleAdmin}
+	}
+	au, ok = mox.Conf.AdminUser(reqInfo.AdminUser)
+	if !ok {
+		xusererrorf(ctx, "admin user no longer exists")
+	}
+	return reqInfo.AdminUser, au
+}
+
+// redactAccountSecrets removes the webhook authorization headers and signing keys
+// from an account config, for admins not allowed to see secrets.
+func redactAccountSecrets(ac *config.Account) {
+	const redacted = "(redacted)"
+	if wh := ac.OutgoingWebhook; wh != nil {
+		x := *wh
+		if x.Authorization != "" {
+			x.Authorization = redacted
+		}
+		if x.SigningKey != "" {
+			x.SigningKey = redacted
+		}
+		ac.OutgoingWebhook = &x
+	}
+	if wh := ac.IncomingWebhook; wh != nil {
+		x := *wh
+		if x.Authorization != "" {
+			x.Authorization = redacted
+		}
+		if x.SigningKey != "" {
+			x.SigningKey = redacted
+		}
+		ac.IncomingWebhook = &x
+	}
+}
+
+// xadminActor returns the admin making the request, for recording with changes.
+func xadminActor(ctx context.Context) string {
+	if name, _ := xadminUser(ctx); name != "" {
+		return "(admin:" + name + ")"
+	}
+	return "(admin)"
+}
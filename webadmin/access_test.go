@@ -0,0 +1,161 @@
+package webadmin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+)
+
+func TestAccess(t *testing.T) {
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/webadmin/mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(filepath.Dir(mox.ConfigStaticPath), "domains.conf")
+	mox.MustLoadConfig(true, false)
+
+	full := config.AdminUser{Role: config.AdminRoleAdmin}
+	auditor := config.AdminUser{Role: config.AdminRoleAuditor}
+	domainAdmin := config.AdminUser{Role: config.AdminRoleDomainAdmin, Domains: []string{"mox.example"}, DNSDomains: []dns.Domain{{ASCII: "mox.example"}}}
+	otherAdmin := config.AdminUser{Role: config.AdminRoleDomainAdmin, Domains: []string{"other.example"}, DNSDomains: []dns.Domain{{ASCII: "other.example"}}}
+
+	test := func(au config.AdminUser, method string, params []any, expOK bool) {
+		t.Helper()
+		var l []json.RawMessage
+		for _, p := range params {
+			buf, err := json.Marshal(p)
+			tcheck(t, err, "marshal param")
+			l = append(l, buf)
+		}
+		err := checkAccess(au, method, l)
+		if expOK && err != nil {
+			t.Fatalf("%s for role %s: got err %v, expected access", method, au.Role, err)
+		} else if !expOK && !errors.Is(err, errAccess) {
+			t.Fatalf("%s for role %s: got err %v, expected errAccess", method, au.Role, err)
+		}
+	}
+
+	test(full, "ConfigFiles", nil, true)
+	test(full, "AdminUserAdd", []any{"x", "auditor", nil, "password"}, true)
+	test(full, "AdminTOTPSetupStart", nil, false)
+	test(auditor, "Logout", nil, true)
+	test(auditor, "AdminTOTPSetupStart", nil, false)
+
+	test(auditor, "QueueList", []any{nil, nil}, true)
+	test(auditor, "Domain", []any{"other.example"}, true)
+	test(auditor, "ConfigFiles", nil, false)
+	test(auditor, "Transports", nil, false)
+	test(auditor, "HookList", []any{nil, nil}, false)
+	test(auditor, "SetPassword", []any{"mjl", "password"}, false)
+	test(auditor, "AdminUsers", nil, false)
+	test(auditor, "Config", nil, false)
+	test(auditor, "ConfigDynamicGet", nil, false)
+	test(auditor, "ConfigDynamicDiff", []any{nil}, false)
+	test(auditor, "Account", []any{"mjl"}, true)
+
+	// Account config for auditors has no webhook secrets.
+	ac := config.Account{
+		OutgoingWebhook: &config.OutgoingWebhook{URL: "https://hooks.example/out", Authorization: "Basic secret", SigningKey: "key"},
+		IncomingWebhook: &config.IncomingWebhook{URL: "https://hooks.example/in", Authorization: "Bearer secret"},
+	}
+	orig := ac.OutgoingWebhook // Shared with the config, must not be modified.
+	redactAccountSecrets(&ac)
+	if ac.OutgoingWebhook.Authorization != "(redacted)" || ac.OutgoingWebhook.SigningKey != "(redacted)" || ac.IncomingWebhook.Authorization != "(redacted)" || ac.IncomingWebhook.SigningKey != "" {
+		t.Fatalf("secrets not redacted: %v %v", ac.OutgoingWebhook, ac.IncomingWebhook)
+	}
+	if orig.Authorization != "Basic secret" {
+		t.Fatalf("original config modified")
+	}
+
+	test(domainAdmin, "Domains", nil, true)
+	test(domainAdmin, "Domain", []any{"mox.example"}, true)
+	test(domainAdmin, "Domain", []any{"Mox.Example"}, true)
+	test(domainAdmin, "Domain", []any{"other.example"}, false)
+	test(domainAdmin, "Domain", []any{1}, false)
+	test(domainAdmin, "Domain", nil, false)
+	test(domainAdmin, "TLSReports", []any{nil, nil, ""}, false)
+	test(domainAdmin, "TLSReports", []any{nil, nil, "mox.example"}, true)
+	test(domainAdmin, "SetPassword", []any{"mjl", "password"}, true)
+	test(otherAdmin, "SetPassword", []any{"mjl", "password"}, false)
+	test(domainAdmin, "SetPassword", []any{"bogus", "password"}, false)
+	test(domainAdmin, "AccountAdd", []any{"new", "new@mox.example"}, true)
+	test(domainAdmin, "AccountAdd", []any{"new", "new@other.example"}, false)
+	test(domainAdmin, "AddressAdd", []any{"@mox.example", "mjl"}, true)
+	test(otherAdmin, "AddressAdd", []any{"x@other.example", "mjl"}, false)
+	test(domainAdmin, "AliasAdd", []any{"list", "mox.example", nil}, true)
+	test(domainAdmin, "QueueList", []any{nil, nil}, false)
+	test(domainAdmin, "DomainAdd", []any{false, "mox.example", "mjl", ""}, false)
+	test(domainAdmin, "DomainDMARCAddressSave", []any{"mox.example", "", "", "", ""}, false)
+	test(domainAdmin, "Bogus", nil, false)
+
+	// Through the HTTP handler, the body must be kept intact.
+	req := httptest.NewRequest("POST", "/api/Domain", strings.NewReader(`{"params": ["other.example"]}`))
+	rr := httptest.NewRecorder()
+	if apiAccessCheck(domainAdmin, rr, req) {
+		t.Fatalf("access allowed, expected denied")
+	}
+	var resp struct {
+		Error struct{ Code string } `json:"error"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&resp)
+	tcheck(t, err, "parse response")
+	if resp.Error.Code != "user:forbidden" {
+		t.Fatalf("got error code %q, expected user:forbidden", resp.Error.Code)
+	}
+
+	body := `{"params": ["mox.example"]}`
+	req = httptest.NewRequest("POST", "/api/Domain", strings.NewReader(body))
+	if !apiAccessCheck(domainAdmin, httptest.NewRecorder(), req) {
+		t.Fatalf("access denied, expected allowed")
+	}
+	buf, err := io.ReadAll(req.Body)
+	tcheck(t, err, "read body")
+	if string(buf) != body {
+		t.Fatalf("got body %q, expected %q", buf, body)
+	}
+
+	// Filtering of domains and accounts for domain admins.
+	withUser := func(name string, au config.AdminUser) context.Context {
+		if mox.Conf.Dynamic.AdminUsers == nil {
+			mox.Conf.Dynamic.AdminUsers = map[string]config.AdminUser{}
+		}
+		mox.Conf.Dynamic.AdminUsers[name] = au
+		return context.WithValue(ctxbg, requestInfoCtxKey, requestInfo{AdminUser: name, Request: &http.Request{}})
+	}
+	defer func() {
+		mox.Conf.Dynamic.AdminUsers = nil
+	}()
+	api := Admin{}
+	if l := api.Domains(withUser("domainadmin", domainAdmin)); len(l) != 1 {
+		t.Fatalf("got %d domains, expected 1", len(l))
+	}
+	if l := api.Domains(withUser("otheradmin", otherAdmin)); len(l) != 0 {
+		t.Fatalf("got %d domains, expected 0", len(l))
+	}
+	if all, _ := api.Accounts(withUser("otheradmin", otherAdmin)); len(all) != 0 {
+		t.Fatalf("got accounts %v, expected none", all)
+	}
+	if all, _ := api.Accounts(withUser("auditor", auditor)); len(all) != 1 {
+		t.Fatalf("got accounts %v, expected 1", all)
+	}
+	if l := api.StorageUsage(withUser("otheradmin", otherAdmin), 0); len(l) != 0 {
+		t.Fatalf("got storage usage %v, expected none", l)
+	}
+	if l := api.StorageUsage(withUser("auditor", auditor), 0); len(l) != 1 || l[0].Account != "mjl" {
+		t.Fatalf("got storage usage %v, expected mjl", l)
+	}
+	name, au := api.AdminSelf(withUser("auditor", auditor))
+	if name != "auditor" || au.Role != config.AdminRoleAuditor {
+		t.Fatalf("got admin self %q %v, expected auditor", name, au)
+	}
+	if name, au := api.AdminSelf(ctxbg); name != "" || au.Role != config.AdminRoleAdmin {
+		t.Fatalf("got admin self %q %v, expected full admin", name, au)
+	}
+}
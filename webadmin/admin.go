@@ -7,6 +7,7 @@ package webadmin
 import (
 	"bufio"
 	"bytes"
+	"cmp"
 	"context"
 	"crypto"
 	"crypto/ed25519"
@@ -55,13 +56,18 @@ import (
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/dnsbl"
 	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlist"
 	"github.com/mjl-/mox/mlog"
 	mox "github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/moxvar"
 	"github.com/mjl-/mox/mtasts"
 	"github.com/mjl-/mox/mtastsdb"
+	"github.com/mjl-/mox/postmasterdb"
+	"github.com/mjl-/mox/provisioning"
 	"github.com/mjl-/mox/publicsuffix"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/reputationdb"
+	"github.com/mjl-/mox/signup"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/spf"
 	"github.com/mjl-/mox/store"
@@ -150,6 +156,7 @@ var requestInfoCtxKey ctxKey = "requestInfo"
 
 type requestInfo struct {
 	SessionToken store.SessionToken
+	AdminUser    string // Empty for login with admin password.
 	Response     http.ResponseWriter
 	Request      *http.Request // For Proto and TLS connection state during message submit.
 }
@@ -178,6 +185,12 @@ func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r
 		return
 	}
 
+	// The provisioning API has its own token authentication.
+	if strings.HasPrefix(r.URL.Path, "/provisioning/") {
+		http.StripPrefix("/provisioning", provisioning.Handler(isForwarded)).ServeHTTP(w, r.WithContext(ctx))
+		return
+	}
+
 	isAPI := strings.HasPrefix(r.URL.Path, "/api/")
 	// Only allow POST for calls, they will not work cross-domain without CORS.
 	if isAPI && r.URL.Path != "/api/" && r.Method != "POST" {
@@ -185,21 +198,36 @@ func handle(apiHandler http.Handler, isForwarded bool, w http.ResponseWriter, r
 		return
 	}
 
-	// All other URLs, except the login endpoint require some authentication.
+	// All other URLs, except the login endpoints require some authentication.
 	var sessionToken store.SessionToken
-	if r.URL.Path != "/api/LoginPrep" && r.URL.Path != "/api/Login" {
+	var adminUser string
+	if !webauth.IsLoginPath(r.URL.Path) {
+		var name string
 		var ok bool
-		_, sessionToken, _, ok = webauth.Check(ctx, log, webauth.Admin, "webadmin", isForwarded, w, r, isAPI, isAPI, false)
+		name, sessionToken, _, ok = webauth.Check(ctx, log, webauth.Admin, "webadmin", isForwarded, w, r, isAPI, isAPI, false)
 		if !ok {
 			// Response has been written already.
 			return
 		}
+		adminUser = webauth.AdminSessionUser(name)
 	}
 
 	if isAPI {
-		reqInfo := requestInfo{sessionToken, w, r}
+		if adminUser != "" {
+			au, ok := mox.Conf.AdminUser(adminUser)
+			if !ok {
+				http.Error(w, "403 - forbidden - unknown admin user", http.StatusForbidden)
+				return
+			}
+			if !apiAccessCheck(au, w, r) {
+				// Response has been written already.
+				return
+			}
+		}
+
+		reqInfo := requestInfo{sessionToken, adminUser, w, r}
 		ctx = context.WithValue(ctx, requestInfoCtxKey, reqInfo)
-		apiHandler.ServeHTTP(w, r.WithContext(ctx))
+		auditCall(ctx, log, apiHandler, isForwarded, adminUser, w, r.WithContext(ctx))
 		return
 	}
 
@@ -258,12 +286,16 @@ func (w Admin) LoginPrep(ctx context.Context) string {
 }
 
 // Login returns a session token for the credentials, or fails with error code
-// "user:badLogin". Call LoginPrep to get a loginToken.
-func (w Admin) Login(ctx context.Context, loginToken, password string) store.CSRFToken {
+// "user:badLogin". Call LoginPrep to get a loginToken. Without username, the
+// admin password from mox.conf is used. Otherwise username is an admin user from
+// domains.conf. If two-factor authentication is enabled for the admin password,
+// totp must be a code from the authenticator app, otherwise the login fails with
+// error code "user:totpRequired".
+func (w Admin) Login(ctx context.Context, loginToken, username, password, totp string) store.CSRFToken {
 	log := pkglog.WithContext(ctx)
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 
-	csrfToken, err := webauth.Login(ctx, log, webauth.Admin, "webadmin", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, "", password)
+	csrfToken, err := webauth.Login(ctx, log, webauth.Admin, "webadmin", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password, totp)
 	if _, ok := err.(*sherpa.Error); ok {
 		panic(err)
 	}
@@ -271,6 +303,37 @@ func (w Admin) Login(ctx context.Context, loginToken, password string) store.CSR
 	return csrfToken
 }
 
+// PasskeyLoginStart returns the parameters for the browser to login with a
+// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+// of the browser.
+func (w Admin) PasskeyLoginStart(ctx context.Context, loginToken string) webauth.PasskeyGetOptions {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	opts, err := webauth.PasskeyLoginStart(ctx, log, "webadmin", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken)
+	if _, ok := err.(*sherpa.Error); ok {
+		panic(err)
+	}
+	xcheckf(ctx, err, "passkey login")
+	return opts
+}
+
+// PasskeyLogin returns a session token for a passkey login started with
+// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+// password, the passkey must verify the user, e.g. with a PIN. With the password,
+// the passkey is the second factor.
+func (w Admin) PasskeyLogin(ctx context.Context, loginToken, password string, assertion webauth.PasskeyAssertion) store.CSRFToken {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	csrfToken, err := webauth.PasskeyLogin(ctx, log, webauth.Admin, "webadmin", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, "", password, assertion)
+	if _, ok := err.(*sherpa.Error); ok {
+		panic(err)
+	}
+	xcheckf(ctx, err, "passkey login")
+	return csrfToken
+}
+
 // Logout invalidates the session token.
 func (w Admin) Logout(ctx context.Context) {
 	log := pkglog.WithContext(ctx)
@@ -1549,9 +1612,16 @@ When enabling MTA-STS, or updating a policy, always update the policy first (thr
 	return
 }
 
-// Domains returns all configured domain names.
+// Domains returns all configured domain names. For domain admins, only their
+// domains are returned.
 func (Admin) Domains(ctx context.Context) []config.Domain {
-	return mox.Conf.DomainConfigs()
+	l := mox.Conf.DomainConfigs()
+	if _, au := xadminUser(ctx); au.Role == config.AdminRoleDomainAdmin {
+		l = slices.DeleteFunc(l, func(d config.Domain) bool {
+			return !slices.Contains(au.DNSDomains, d.Domain)
+		})
+	}
+	return l
 }
 
 // Domain returns the dns domain for a (potentially unicode as IDNA) domain name.
@@ -1594,9 +1664,19 @@ func (Admin) DomainLocalparts(ctx context.Context, domain string) (localpartAcco
 	return mox.Conf.DomainLocalparts(d)
 }
 
-// Accounts returns the names of all configured and all disabled accounts.
+// Accounts returns the names of all configured and all disabled accounts. For
+// domain admins, only accounts with one of their domains as default domain are
+// returned.
 func (Admin) Accounts(ctx context.Context) (all, disabled []string) {
 	all, disabled = mox.Conf.AccountsDisabled()
+	if _, au := xadminUser(ctx); au.Role == config.AdminRoleDomainAdmin {
+		other := func(name string) bool {
+			ac, ok := mox.Conf.Account(name)
+			return !ok || !slices.Contains(au.DNSDomains, ac.DNSDomain)
+		}
+		all = slices.DeleteFunc(all, other)
+		disabled = slices.DeleteFunc(disabled, other)
+	}
 	slices.Sort(all)
 	return
 }
@@ -1628,6 +1708,9 @@ func (Admin) Account(ctx context.Context, account string) (accountConfig config.
 		xcheckf(ctx, err, "get disk usage")
 	})
 
+	if _, au := xadminUser(ctx); au.Role == config.AdminRoleAuditor {
+		redactAccountSecrets(&ac)
+	}
 	return ac, diskUsage
 }
 
@@ -1735,6 +1818,35 @@ func (Admin) TLSRPTSummaries(ctx context.Context, start, end time.Time, policyDo
 	return sums
 }
 
+// TLSRPTStats returns statistics about failure types and trends for TLS reports
+// overlapping with period start/end, for one or all policy domains (when domain is
+// empty).
+func (Admin) TLSRPTStats(ctx context.Context, start, end time.Time, policyDomain string) (stats tlsrptdb.Stats) {
+	var polDom dns.Domain
+	if policyDomain != "" {
+		var err error
+		polDom, err = dns.ParseDomain(policyDomain)
+		xcheckuserf(ctx, err, "parsing policy domain")
+	}
+	stats, err := tlsrptdb.Analyze(ctx, start, end, polDom)
+	xcheckf(ctx, err, "analyzing tls reports")
+	return stats
+}
+
+// TLSRPTFailureTypes returns the failure types seen in TLS reports for one or
+// all policy domains (when domain is empty), most recently seen first.
+func (Admin) TLSRPTFailureTypes(ctx context.Context, policyDomain string) []tlsrptdb.FailureType {
+	var polDom dns.Domain
+	if policyDomain != "" {
+		var err error
+		polDom, err = dns.ParseDomain(policyDomain)
+		xcheckuserf(ctx, err, "parsing policy domain")
+	}
+	l, err := tlsrptdb.FailureTypes(ctx, polDom)
+	xcheckf(ctx, err, "listing tls failure types")
+	return l
+}
+
 // DMARCReports returns DMARC reports overlapping with period start/end, for the
 // given domain (or all domains if empty). The reports are sorted first by period
 // end (most recent first), then by domain.
@@ -1765,6 +1877,27 @@ func (Admin) DMARCReportID(ctx context.Context, domain string, reportID int64) (
 	return report
 }
 
+// DMARCFailureReports returns DMARC failure reports received during period
+// start/end, for the given domain (or all domains if empty), most recent first.
+func (Admin) DMARCFailureReports(ctx context.Context, start, end time.Time, domain string) (reports []dmarcdb.DomainFailureReport) {
+	reports, err := dmarcdb.FailureReportsPeriodDomain(ctx, start, end, domain)
+	xcheckf(ctx, err, "fetching dmarc failure reports from database")
+	return reports
+}
+
+// DMARCFailureReportID returns a single DMARC failure report.
+func (Admin) DMARCFailureReportID(ctx context.Context, domain string, reportID int64) (report dmarcdb.DomainFailureReport) {
+	report, err := dmarcdb.FailureReportID(ctx, reportID)
+	if err == nil && report.Domain != domain {
+		err = bstore.ErrAbsent
+	}
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, err, "fetching dmarc failure report from database")
+	}
+	xcheckf(ctx, err, "fetching dmarc failure report from database")
+	return report
+}
+
 // DMARCSummary presents DMARC aggregate reporting statistics for a single domain
 // over a period.
 type DMARCSummary struct {
@@ -1828,6 +1961,82 @@ func (Admin) DMARCSummaries(ctx context.Context, start, end time.Time, domain st
 	return sums
 }
 
+// DMARCAnalytics returns statistics and trends for DMARC reports overlapping
+// with period start/end, for the given domain (or all domains if empty). At most
+// 20 sources, failing sources and reporters are returned.
+func (Admin) DMARCAnalytics(ctx context.Context, start, end time.Time, domain string) (analytics dmarcdb.Analytics) {
+	analytics, err := dmarcdb.Analyze(ctx, start, end, domain, 20)
+	xcheckf(ctx, err, "analyzing dmarc aggregate reports")
+	return analytics
+}
+
+// PostmasterData holds reputation data fetched from mailbox providers, and our
+// outgoing delivery volume to those providers, for a period.
+type PostmasterData struct {
+	SNDS    []postmasterdb.SNDSRecord
+	Google  []postmasterdb.GoogleStats
+	Volumes []postmasterdb.Volume
+}
+
+// Postmaster returns reputation data from Microsoft SNDS and Google Postmaster
+// Tools for the period start/end, along with our outgoing delivery volume to
+// those providers during the period.
+func (Admin) Postmaster(ctx context.Context, start, end time.Time) (data PostmasterData) {
+	var err error
+	data.SNDS, err = postmasterdb.SNDSList(ctx, start, end)
+	xcheckf(ctx, err, "listing snds data")
+	data.Google, err = postmasterdb.GoogleList(ctx, start, end)
+	xcheckf(ctx, err, "listing google postmaster tools data")
+	data.Volumes, err = postmasterdb.VolumeList(ctx, start, end)
+	xcheckf(ctx, err, "gathering outgoing delivery volume")
+	return
+}
+
+// SenderReputations returns the server-wide reputation of sending domains and
+// IPs of incoming deliveries, most recently seen first. Typ can be "domain", "ip"
+// or empty for both. If overrides is set, only senders with an override are
+// returned. At most 1000 senders are returned.
+func (Admin) SenderReputations(ctx context.Context, typ string, overrides bool) []reputationdb.Sender {
+	l, err := reputationdb.List(ctx, reputationdb.SenderType(typ), overrides, 1000)
+	xcheckf(ctx, err, "listing sender reputations")
+	return l
+}
+
+// SenderReputationOverride sets an override for the server-wide reputation of a
+// sending domain or IP, or clears it if override is empty. A domain is changed to
+// its organizational domain, an IPv6 address to its /64 network.
+func (Admin) SenderReputationOverride(ctx context.Context, typ reputationdb.SenderType, value string, override reputationdb.Override) {
+	log := pkglog.WithContext(ctx)
+
+	switch typ {
+	case reputationdb.SenderDomain:
+		d, err := dns.ParseDomain(value)
+		xcheckuserf(ctx, err, "parsing domain")
+		value = publicsuffix.Lookup(ctx, log.Logger, d).Name()
+	case reputationdb.SenderIP:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			xusererrorf(ctx, "invalid ip %q", value)
+		}
+		if ip.To4() != nil {
+			value = ip.String()
+		} else {
+			value = ip.Mask(net.CIDRMask(64, 128)).String()
+		}
+	default:
+		xusererrorf(ctx, "unknown sender type %q", typ)
+	}
+	switch override {
+	case reputationdb.OverrideNone, reputationdb.OverrideAllow, reputationdb.OverrideQuarantine:
+	default:
+		xusererrorf(ctx, "unknown override %q", override)
+	}
+
+	err := reputationdb.SetOverride(ctx, typ, value, override)
+	xcheckf(ctx, err, "setting sender reputation override")
+	log.Info("sender reputation override set", slog.Any("type", typ), slog.String("value", value), slog.Any("override", override))
+}
+
 // Reverse is the result of a reverse lookup.
 type Reverse struct {
 	Hostnames []string
@@ -1843,6 +2052,59 @@ func (Admin) LookupIP(ctx context.Context, ip string) Reverse {
 	return Reverse{names}
 }
 
+// SPFAnalyze looks up the SPF record of a domain and expands it fully, following
+// includes and redirects. The analysis reports the number of DNS lookups against
+// the limit of 10, void lookups, and problems such as overlapping IP networks.
+// Useful for our own domains, and for debugging delivery to remote domains.
+//
+// If ip is not empty, it is used for expanding macros, and is evaluated against
+// the record, with the resulting status and matching mechanism returned.
+func (Admin) SPFAnalyze(ctx context.Context, domain, ip string) (analysis spf.Analysis, status spf.Status, mechanism string, errstr string) {
+	log := pkglog.WithContext(ctx)
+	dom, err := dns.ParseDomain(domain)
+	xcheckuserf(ctx, err, "parsing domain")
+
+	var remoteIP net.IP
+	if ip != "" {
+		remoteIP = net.ParseIP(ip)
+		if remoteIP == nil {
+			xcheckuserf(ctx, errors.New("invalid ip"), "parsing ip")
+		}
+	}
+
+	resolver := dns.StrictResolver{Pkg: "webadmin", Log: log.Logger}
+	args := spf.Args{
+		RemoteIP:          remoteIP,
+		MailFromLocalpart: "postmaster",
+		MailFromDomain:    dom,
+		HelloDomain:       dns.IPDomain{Domain: dom},
+		LocalIP:           net.ParseIP("127.0.0.1"),
+		LocalHostname:     mox.Conf.Static.HostnameDomain,
+	}
+	analysis, err = spf.Analyze(ctx, log.Logger, resolver, args)
+	xcheckf(ctx, err, "analyzing spf record")
+
+	if remoteIP != nil {
+		var received spf.Received
+		received, _, _, _, err = spf.Verify(ctx, log.Logger, resolver, args)
+		if err != nil {
+			errstr = err.Error()
+		}
+		status, mechanism = received.Result, received.Mechanism
+	}
+	return analysis, status, mechanism, errstr
+}
+
+// DANEHostStatus returns the DANE TLSA records for the keys of the current and
+// next TLS certificates of the mail host, and checks them against the records in
+// DNS, warning about key rollovers that would break DANE verification.
+func (Admin) DANEHostStatus(ctx context.Context) admin.DANEHost {
+	resolver := dns.StrictResolver{Pkg: "webadmin", Log: pkglog.WithContext(ctx).Logger}
+	r, err := admin.DANEHostStatus(ctx, resolver)
+	xcheckf(ctx, err, "checking dane records for host")
+	return r
+}
+
 // DNSBLStatus returns the IPs from which outgoing connections may be made and
 // their current status in DNSBLs that are configured. The IPs are typically the
 // configured listen IPs, or otherwise IPs on the machines network interfaces, with
@@ -1967,6 +2229,11 @@ func (Admin) DomainAdd(ctx context.Context, disabled bool, domain, accountName,
 
 	err = admin.DomainAdd(ctx, disabled, d, accountName, smtp.Localpart(norm.NFC.String(localpart)))
 	xcheckf(ctx, err, "adding domain")
+
+	// Start checking the new domain, for its readiness checklist.
+	readiness.Lock()
+	readinessRefresh(d.Name())
+	readiness.Unlock()
 }
 
 // DomainRemove removes an existing domain and reloads the configuration.
@@ -1985,6 +2252,20 @@ func (Admin) AccountAdd(ctx context.Context, accountName, address string) {
 	xcheckf(ctx, err, "adding account")
 }
 
+// AccountsImport adds multiple accounts from text in CSV or JSON format, see
+// admin.ParseAccountImports. Either all accounts are added or none. The results
+// have an error for each account that cannot be added, or the initial password
+// for each added account. With dryRun, the accounts are only validated.
+func (Admin) AccountsImport(ctx context.Context, text string, dryRun, requireChange bool) []admin.AccountImportResult {
+	accounts, err := admin.ParseAccountImports([]byte(text))
+	xcheckf(ctx, err, "parsing accounts")
+	results, err := admin.AccountsImport(ctx, accounts, dryRun, requireChange)
+	if results == nil {
+		xcheckf(ctx, err, "importing accounts")
+	}
+	return results
+}
+
 // AccountRemove removes an existing account and reloads the configuration.
 func (Admin) AccountRemove(ctx context.Context, accountName string) {
 	err := admin.AccountRemove(ctx, accountName)
@@ -2021,6 +2302,360 @@ func (Admin) SetPassword(ctx context.Context, accountName, password string) {
 	xcheckf(ctx, err, "setting password")
 }
 
+// PasswordRequireChange marks the password of an account as expired. A new
+// password must be set through the account web interface before logging in with
+// other protocols. Sessions of the account are removed.
+func (Admin) PasswordRequireChange(ctx context.Context, accountName string) {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.WithContext(ctx).Check(err, "closing account")
+	}()
+	err = acc.PasswordRequireChange(log)
+	xcheckf(ctx, err, "requiring password change")
+}
+
+// AccountSendSuspension returns the suspension of sending for an account after
+// an anomaly in outgoing messages, or nil if sending is not suspended.
+func (Admin) AccountSendSuspension(ctx context.Context, accountName string) *store.SendSuspension {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.WithContext(ctx).Check(err, "closing account")
+	}()
+	ss, err := acc.SendSuspensionGet(ctx)
+	xcheckf(ctx, err, "get send suspension")
+	return ss
+}
+
+// AccountSendResume resumes sending for an account that was suspended after an
+// anomaly in outgoing messages.
+func (Admin) AccountSendResume(ctx context.Context, accountName string) {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.WithContext(ctx).Check(err, "closing account")
+	}()
+	err = acc.SendResume(ctx)
+	xcheckf(ctx, err, "resuming sending")
+}
+
+// AccountStorage returns the number of messages in an account, and their total
+// uncompressed and stored sizes, see MessageCompression.
+func (Admin) AccountStorage(ctx context.Context, accountName string) store.MessageStorage {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.WithContext(ctx).Check(err, "closing account")
+	}()
+	ms, err := acc.MessageStorage(ctx)
+	xcheckf(ctx, err, "get message storage")
+	return ms
+}
+
+// AccountPasswordPolicySave sets the password policy for an account, with
+// requirements for passwords, expiry and lockout after failed logins. A nil
+// policy removes it.
+func (Admin) AccountPasswordPolicySave(ctx context.Context, accountName string, policy *config.PasswordPolicy) {
+	err := admin.AccountSave(ctx, accountName, func(acc *config.Account) {
+		acc.PasswordPolicy = policy
+	})
+	xcheckf(ctx, err, "saving password policy")
+}
+
+// AccountTwoFactorEnabled returns whether the account has enabled two-factor
+// authentication.
+func (Admin) AccountTwoFactorEnabled(ctx context.Context, accountName string) bool {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	enabled, err := acc.TwoFactorEnabled()
+	xcheckf(ctx, err, "checking two-factor authentication")
+	return enabled
+}
+
+// AccountRequireTwoFactorSave sets whether the account must use two-factor
+// authentication.
+func (Admin) AccountRequireTwoFactorSave(ctx context.Context, accountName string, require bool) {
+	err := admin.AccountSave(ctx, accountName, func(acc *config.Account) {
+		acc.RequireTwoFactor = require
+	})
+	xcheckf(ctx, err, "saving two-factor authentication requirement")
+}
+
+// AccountTOTPRemove disables two-factor authentication for an account, e.g.
+// after the account lost its authenticator app. If two-factor authentication is
+// required, the account has to set it up again at the next login.
+func (Admin) AccountTOTPRemove(ctx context.Context, accountName string) {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	err = acc.TOTPRemove(log)
+	xcheckf(ctx, err, "removing two-factor authentication")
+}
+
+// AccountJunkFilterStats returns the number of trained messages and known words
+// of the junk filter of an account, and of the shared junk filter of its domain,
+// if configured.
+func (Admin) AccountJunkFilterStats(ctx context.Context, accountName string) store.JunkFilterStats {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	stats, err := acc.JunkFilterStats(ctx, log)
+	if errors.Is(err, store.ErrNoJunkFilter) {
+		xcheckuserf(ctx, err, "junk filter statistics")
+	}
+	xcheckf(ctx, err, "junk filter statistics")
+	return stats
+}
+
+// JunkVerdict is a message delivered over SMTP with the outcome of its junk
+// analysis.
+type JunkVerdict struct {
+	MessageID int64
+	Received  time.Time
+	Mailbox   string // Current mailbox of the message.
+	From      string // Address from message From header, can be empty.
+	Subject   string
+	Junk      bool // Current junk flag, e.g. after being marked by the user.
+	Notjunk   bool
+	Verdict   store.MessageJunkVerdict
+}
+
+// AccountJunkVerdicts returns the most recently delivered messages of an account
+// with the outcome of their junk analysis, for debugging misclassifications. If
+// junkOnly is set, only messages that were rejected or are marked as junk are
+// returned. If limit is greater than 0, at most limit messages are returned.
+func (Admin) AccountJunkVerdicts(ctx context.Context, accountName string, junkOnly bool, limit int) []JunkVerdict {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	l := []JunkVerdict{}
+	err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		mailboxes := map[int64]string{}
+		err := bstore.QueryTx[store.Mailbox](tx).ForEach(func(mb store.Mailbox) error {
+			mailboxes[mb.ID] = mb.Name
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("listing mailboxes: %v", err)
+		}
+
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterEqual("Expunged", false)
+		q.FilterFn(func(m store.Message) bool {
+			return m.JunkVerdict != nil && (!junkOnly || !m.JunkVerdict.Accept || m.Junk)
+		})
+		q.SortDesc("Received")
+		if limit > 0 {
+			q.Limit(limit)
+		}
+		return q.ForEach(func(m store.Message) error {
+			v := JunkVerdict{
+				MessageID: m.ID,
+				Received:  m.Received,
+				Mailbox:   mailboxes[m.MailboxID],
+				Junk:      m.Junk,
+				Notjunk:   m.Notjunk,
+				Verdict:   *m.JunkVerdict,
+			}
+			if m.MsgFromDomain != "" {
+				v.From = m.MsgFromLocalpart.String() + "@" + m.MsgFromDomain
+			}
+			if p, err := m.LoadPart(nil); err != nil {
+				log.Debugx("loading message part for subject", err)
+			} else if p.Envelope != nil {
+				v.Subject = p.Envelope.Subject
+			}
+			l = append(l, v)
+			return nil
+		})
+	})
+	xcheckf(ctx, err, "listing junk verdicts")
+	return l
+}
+
+// AccountJunkFilterRetrain removes the junk filter of an account and trains a new
+// junk filter from scratch with all messages marked as junk or not junk. The
+// account is locked while retraining. Returns the number of messages in the
+// account and the number of trained messages.
+func (Admin) AccountJunkFilterRetrain(ctx context.Context, accountName string) (total, trained int) {
+	log := pkglog.WithContext(ctx)
+	acc, err := store.OpenAccount(log, accountName, false)
+	xcheckf(ctx, err, "open account")
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	total, trained, err = acc.RetrainJunkFilter(ctx, log)
+	if errors.Is(err, store.ErrNoJunkFilter) {
+		xcheckuserf(ctx, err, "retraining junk filter")
+	}
+	xcheckf(ctx, err, "retraining junk filter")
+	return
+}
+
+// AccountPasskeys returns the passkeys registered by an account.
+func (Admin) AccountPasskeys(ctx context.Context, accountName string) []store.Passkey {
+	if accountName == "" {
+		xcheckuserf(ctx, errors.New("account name required"), "listing passkeys")
+	}
+	l, err := store.PasskeyList(ctx, accountName)
+	xcheckf(ctx, err, "listing passkeys")
+	return l
+}
+
+// AccountPasskeyRemove removes a passkey of an account, e.g. for a lost device.
+func (Admin) AccountPasskeyRemove(ctx context.Context, accountName, credentialID string) {
+	if accountName == "" {
+		xcheckuserf(ctx, errors.New("account name required"), "removing passkey")
+	}
+	err := store.PasskeyRemove(ctx, accountName, credentialID)
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, errors.New("passkey not found"), "removing passkey")
+	}
+	xcheckf(ctx, err, "removing passkey")
+}
+
+// DomainRequireTwoFactorSave sets whether accounts with the domain as their
+// default domain must use two-factor authentication.
+func (Admin) DomainRequireTwoFactorSave(ctx context.Context, domainName string, require bool) {
+	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
+		domain.RequireTwoFactor = require
+		return nil
+	})
+	xcheckf(ctx, err, "saving two-factor authentication requirement")
+}
+
+// AdminTOTPEnabled returns whether admin logins require a code from an
+// authenticator app.
+func (Admin) AdminTOTPEnabled(ctx context.Context) bool {
+	enabled, err := webauth.AdminTOTPEnabled()
+	xcheckf(ctx, err, "checking two-factor authentication")
+	return enabled
+}
+
+// AdminTOTPSetupStart starts enabling two-factor authentication for admin
+// logins, returning the parameters for the authenticator app. Call
+// AdminTOTPSetupConfirm with a code from the app to enable it.
+func (Admin) AdminTOTPSetupStart(ctx context.Context) webauth.TOTPSetup {
+	setup, err := webauth.NewTOTPSetup("admin", webauth.AdminTOTPSetupStart())
+	xcheckf(ctx, err, "preparing two-factor authentication setup")
+	return setup
+}
+
+// AdminTOTPSetupConfirm enables two-factor authentication for admin logins
+// after verifying a code from the authenticator app.
+func (Admin) AdminTOTPSetupConfirm(ctx context.Context, code string) {
+	err := webauth.AdminTOTPSetupConfirm(code)
+	if errors.Is(err, store.ErrUnknownCredentials) {
+		xcheckuserf(ctx, errors.New("invalid code"), "confirming two-factor authentication")
+	}
+	xcheckuserf(ctx, err, "confirming two-factor authentication")
+}
+
+// AdminTOTPRemove disables two-factor authentication for admin logins.
+func (Admin) AdminTOTPRemove(ctx context.Context) {
+	err := webauth.AdminTOTPRemove()
+	xcheckf(ctx, err, "disabling two-factor authentication")
+}
+
+// AdminPasskeys returns the passkeys registered for admin logins.
+func (Admin) AdminPasskeys(ctx context.Context) []store.Passkey {
+	l, err := store.PasskeyList(ctx, "")
+	xcheckf(ctx, err, "listing passkeys")
+	return l
+}
+
+// AdminPasskeyRegisterStart starts registration of a new passkey for admin
+// logins, returning the parameters for the browser. Complete the registration
+// with AdminPasskeyRegister.
+func (w Admin) AdminPasskeyRegisterStart(ctx context.Context) webauth.PasskeyCreateOptions {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	opts, err := webauth.PasskeyRegisterStart(ctx, "webadmin", w.isForwarded, reqInfo.Request, "", "admin")
+	xcheckf(ctx, err, "starting passkey registration")
+	return opts
+}
+
+// AdminPasskeyRegister completes registration of a passkey for admin logins with
+// the response from the browser. Name describes the passkey, e.g. the device.
+func (w Admin) AdminPasskeyRegister(ctx context.Context, name string, clientDataJSON, attestationObject []byte) store.Passkey {
+	log := pkglog.WithContext(ctx)
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	pk, err := webauth.PasskeyRegister(ctx, log, "webadmin", w.isForwarded, reqInfo.Request, "", "", name, clientDataJSON, attestationObject)
+	xcheckuserf(ctx, err, "registering passkey")
+	return pk
+}
+
+// AdminPasskeyRemove removes a passkey for admin logins.
+func (Admin) AdminPasskeyRemove(ctx context.Context, credentialID string) {
+	err := store.PasskeyRemove(ctx, "", credentialID)
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, errors.New("passkey not found"), "removing passkey")
+	}
+	xcheckf(ctx, err, "removing passkey")
+}
+
+// AdminSelf returns the admin user of the session, with an empty name for logins
+// with the admin password.
+func (Admin) AdminSelf(ctx context.Context) (name string, adminUser config.AdminUser) {
+	return xadminUser(ctx)
+}
+
+// AdminUsers returns the named admin users from domains.conf.
+func (Admin) AdminUsers(ctx context.Context) map[string]config.AdminUser {
+	return mox.Conf.AdminUsers()
+}
+
+// AdminUserAdd adds an admin user with a role, and domains for domain admins.
+func (Admin) AdminUserAdd(ctx context.Context, name, role string, domains []string, password string) {
+	err := admin.AdminUserAdd(ctx, name, role, domains, password)
+	xcheckf(ctx, err, "adding admin user")
+}
+
+// AdminUserUpdate changes the role and domains of an admin user.
+func (Admin) AdminUserUpdate(ctx context.Context, name, role string, domains []string) {
+	err := admin.AdminUserUpdate(ctx, name, role, domains)
+	xcheckf(ctx, err, "updating admin user")
+}
+
+// AdminUserSetPassword sets a new password for an admin user.
+func (Admin) AdminUserSetPassword(ctx context.Context, name, password string) {
+	err := admin.AdminUserSetPassword(ctx, name, password)
+	xcheckf(ctx, err, "setting admin user password")
+}
+
+// AdminUserRemove removes an admin user.
+func (Admin) AdminUserRemove(ctx context.Context, name string) {
+	err := admin.AdminUserRemove(ctx, name)
+	xcheckf(ctx, err, "removing admin user")
+}
+
 // AccountSettingsSave set new settings for an account that only an admin can set.
 func (Admin) AccountSettingsSave(ctx context.Context, accountName string, maxOutgoingMessagesPerDay, maxFirstTimeRecipientsPerDay int, maxMsgSize int64, firstTimeSenderDelay, noCustomPassword bool) {
 	err := admin.AccountSave(ctx, accountName, func(acc *config.Account) {
@@ -2053,6 +2688,107 @@ func (Admin) AccountLoginDisabledSave(ctx context.Context, accountName string, l
 	xcheckf(ctx, err, "removing current sessions")
 }
 
+// AccountImpersonate starts a webmail session as an account, for troubleshooting.
+// The returned single-use token must be opened within 1 minute, at
+// "impersonate?token=..." of the webmail interface, e.g. at webmailPath on the
+// same host. The session ends after the given number of minutes, at most 8
+// hours. With readOnly, no changes can be made through the session. The start of
+// the session and all API calls made through it are recorded in the audit log, and
+// the webmail interface shows a banner while impersonating.
+func (Admin) AccountImpersonate(ctx context.Context, accountName string, readOnly bool, minutes int) (token string, webmailPath string) {
+	token, err := webauth.ImpersonateStart("webmail", accountName, xadminActor(ctx), readOnly, time.Duration(minutes)*time.Minute)
+	xcheckuserf(ctx, err, "starting impersonation")
+
+	// Prefer webmail over HTTPS.
+	for _, lname := range slices.Sorted(maps.Keys(mox.Conf.Static.Listeners)) {
+		l := mox.Conf.Static.Listeners[lname]
+		if l.WebmailHTTPS.Enabled {
+			return token, cmp.Or(l.WebmailHTTPS.Path, "/webmail/")
+		} else if l.WebmailHTTP.Enabled && webmailPath == "" {
+			webmailPath = cmp.Or(l.WebmailHTTP.Path, "/webmail/")
+		}
+	}
+	return token, cmp.Or(webmailPath, "/webmail/")
+}
+
+// SignupRequests returns all self-service signup requests, most recent first.
+func (Admin) SignupRequests(ctx context.Context) []store.SignupRequest {
+	l, err := store.SignupRequestList(ctx, "")
+	xcheckf(ctx, err, "listing signup requests")
+	return l
+}
+
+// SignupApprove approves a pending signup request, creating the account and
+// notifying the requester.
+func (Admin) SignupApprove(ctx context.Context, id int64) {
+	_, err := signup.Approve(ctx, pkglog.WithContext(ctx), id, xadminActor(ctx))
+	xcheckf(ctx, err, "approving signup request")
+}
+
+// SignupReject rejects a pending signup request, notifying the requester with
+// the optional reason.
+func (Admin) SignupReject(ctx context.Context, id int64, reason string) {
+	err := signup.Reject(ctx, pkglog.WithContext(ctx), id, xadminActor(ctx), reason)
+	xcheckf(ctx, err, "rejecting signup request")
+}
+
+// SignupInvites returns all signup invitations, most recent first.
+func (Admin) SignupInvites(ctx context.Context) []store.SignupInvite {
+	l, err := store.SignupInviteList(ctx)
+	xcheckf(ctx, err, "listing signup invitations")
+	return l
+}
+
+// SignupInviteAdd adds an invitation for signups at a domain, valid for the
+// given number of days. The token is only returned once, the invitation link is
+// "?invite=..." at signupPath.
+func (Admin) SignupInviteAdd(ctx context.Context, domainName string, maxUses, days int, note string) (token string, signupPath string) {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	domConf, ok := mox.Conf.Domain(d)
+	if !ok {
+		xusererrorf(ctx, "unknown domain")
+	} else if domConf.Signup == nil {
+		xusererrorf(ctx, "signup is not enabled for domain")
+	}
+	if days <= 0 {
+		xusererrorf(ctx, "days must be positive")
+	}
+	name, _ := xadminUser(ctx)
+	token, err = store.SignupInviteAdd(ctx, d.Name(), maxUses, time.Now().Add(time.Duration(days)*24*time.Hour), note, cmp.Or(name, "admin"))
+	xcheckuserf(ctx, err, "adding signup invitation")
+
+	// Prefer the account interface over HTTPS.
+	for _, lname := range slices.Sorted(maps.Keys(mox.Conf.Static.Listeners)) {
+		l := mox.Conf.Static.Listeners[lname]
+		if l.AccountHTTPS.Enabled {
+			return token, cmp.Or(l.AccountHTTPS.Path, "/") + "signup/"
+		} else if l.AccountHTTP.Enabled && signupPath == "" {
+			signupPath = cmp.Or(l.AccountHTTP.Path, "/") + "signup/"
+		}
+	}
+	return token, cmp.Or(signupPath, "/signup/")
+}
+
+// SignupInviteRemove removes a signup invitation.
+func (Admin) SignupInviteRemove(ctx context.Context, id int64) {
+	err := store.SignupInviteRemove(ctx, id)
+	xcheckf(ctx, err, "removing signup invitation")
+}
+
+// DomainSignupSave saves the self-service signup settings for a domain. A nil
+// signup disables signups for the domain.
+func (Admin) DomainSignupSave(ctx context.Context, domainName string, signup *config.DomainSignup) {
+	if signup != nil && signup.QuotaMessageSize < 0 {
+		xusererrorf(ctx, "quota cannot be negative")
+	}
+	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
+		domain.Signup = signup
+		return nil
+	})
+	xcheckf(ctx, err, "saving domain signup settings")
+}
+
 // ClientConfigsDomain returns configurations for email clients, IMAP and
 // Submission (SMTP) for the domain.
 func (Admin) ClientConfigsDomain(ctx context.Context, domain string) admin.ClientConfigs {
@@ -2101,13 +2837,48 @@ func (Admin) QueueHoldRuleRemove(ctx context.Context, holdRuleID int64) {
 	xcheckf(ctx, err, "removing queue hold rule")
 }
 
-// QueueList returns the messages currently in the outgoing queue.
+// QueueHoldRuleResume removes a hold rule and takes matching messages in the
+// queue off hold, unless they also match another hold rule.
+func (Admin) QueueHoldRuleResume(ctx context.Context, holdRuleID int64) (affected int) {
+	log := pkglog.WithContext(ctx)
+	n, err := queue.HoldRuleResume(ctx, log, holdRuleID)
+	xcheckf(ctx, err, "resuming queue hold rule")
+	return n
+}
+
+// QueueList returns the messages currently in the outgoing queue. SMTP
+// transcripts are not included, see QueueMsgTranscript.
 func (Admin) QueueList(ctx context.Context, filter queue.Filter, sort queue.Sort) []queue.Msg {
 	l, err := queue.List(ctx, filter, sort)
 	xcheckf(ctx, err, "listing messages in queue")
+	// Transcripts can be large, for lists with many messages.
+	for i := range l {
+		for j := range l[i].Results {
+			l[i].Results[j].Transcript = ""
+		}
+	}
 	return l
 }
 
+// QueueCount returns the number of messages in the outgoing queue matching
+// filter, ignoring its Max.
+func (Admin) QueueCount(ctx context.Context, filter queue.Filter) int {
+	n, err := queue.CountFilter(ctx, filter)
+	xcheckf(ctx, err, "counting messages in queue")
+	return n
+}
+
+// QueueMsgTranscript returns the result of the most recent delivery attempt of
+// a message in the queue that has an SMTP transcript.
+func (Admin) QueueMsgTranscript(ctx context.Context, id int64) queue.MsgResult {
+	r, err := queue.Transcript(ctx, id)
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, err, "get message")
+	}
+	xcheckf(ctx, err, "get transcript for message")
+	return r
+}
+
 // QueueNextAttemptSet sets a new time for next delivery attempt of matching
 // messages from the queue.
 func (Admin) QueueNextAttemptSet(ctx context.Context, filter queue.Filter, minutes int) (affected int) {
@@ -2163,6 +2934,19 @@ func (Admin) QueueTransportSet(ctx context.Context, filter queue.Filter, transpo
 	return n
 }
 
+// QueueMsgRewrite changes the recipient, transport, and/or the From and Subject
+// headers of a message in the queue, and schedules it for immediate delivery. The
+// changes are kept with the message as audit trail.
+func (Admin) QueueMsgRewrite(ctx context.Context, id int64, rewrite queue.MsgRewrite) queue.Msg {
+	log := pkglog.WithContext(ctx)
+	m, err := queue.Rewrite(ctx, log, id, rewrite)
+	if err == bstore.ErrAbsent || errors.Is(err, queue.ErrRewrite) {
+		xcheckuserf(ctx, err, "rewriting message")
+	}
+	xcheckf(ctx, err, "rewriting message in queue")
+	return m
+}
+
 // RetiredList returns messages retired from the queue (delivery could
 // have succeeded or failed).
 func (Admin) RetiredList(ctx context.Context, filter queue.RetiredFilter, sort queue.RetiredSort) []queue.MsgRetired {
@@ -2171,6 +2955,60 @@ func (Admin) RetiredList(ctx context.Context, filter queue.RetiredFilter, sort q
 	return l
 }
 
+// QueueDeadList returns messages in the dead-letter queue, for which delivery
+// failed permanently.
+func (Admin) QueueDeadList(ctx context.Context, filter queue.DeadFilter) []queue.MsgDead {
+	l, err := queue.DeadList(ctx, filter)
+	xcheckf(ctx, err, "listing dead-letter queue")
+	return l
+}
+
+// QueueDeadRecipientSet changes the recipient address of a message in the
+// dead-letter queue.
+func (Admin) QueueDeadRecipientSet(ctx context.Context, id int64, address string) {
+	addr, err := smtp.ParseAddress(address)
+	xcheckuserf(ctx, err, "parsing address")
+	log := pkglog.WithContext(ctx)
+	err = queue.DeadRecipientSet(ctx, log, id, addr.Path())
+	xcheckf(ctx, err, "changing recipient of message in dead-letter queue")
+}
+
+// QueueDeadRequeue adds matching messages from the dead-letter queue back to
+// the queue for delivery.
+func (Admin) QueueDeadRequeue(ctx context.Context, filter queue.DeadFilter) (affected int) {
+	log := pkglog.WithContext(ctx)
+	n, err := queue.DeadRequeue(ctx, log, filter)
+	xcheckf(ctx, err, "requeueing messages from dead-letter queue")
+	return n
+}
+
+// QueueDeadRemove removes matching messages from the dead-letter queue.
+func (Admin) QueueDeadRemove(ctx context.Context, filter queue.DeadFilter) (affected int) {
+	log := pkglog.WithContext(ctx)
+	n, err := queue.DeadRemove(ctx, log, filter)
+	xcheckf(ctx, err, "removing messages from dead-letter queue")
+	return n
+}
+
+// QueueHostHealthList returns the delivery health of remote hosts. Hosts in a
+// cooldown period after failures are tried after other hosts with the same MX
+// preference.
+func (Admin) QueueHostHealthList(ctx context.Context) []queue.HostHealth {
+	l, err := queue.HostHealthList(ctx)
+	xcheckf(ctx, err, "listing host health")
+	return l
+}
+
+// QueueHostHealthReset removes the delivery health record for a remote host,
+// ending its cooldown period.
+func (Admin) QueueHostHealthReset(ctx context.Context, host string) {
+	err := queue.HostHealthReset(ctx, host)
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, err, "looking up host health")
+	}
+	xcheckf(ctx, err, "resetting host health")
+}
+
 // HookQueueSize returns the number of webhooks still to be delivered.
 func (Admin) HookQueueSize(ctx context.Context) int {
 	n, err := queue.HookQueueSize(ctx)
@@ -2488,6 +3326,47 @@ func (Admin) Config(ctx context.Context) config.Dynamic {
 	return mox.Conf.DynamicConfig()
 }
 
+// ConfigDynamicGet returns the dynamic config, as structured data and in
+// domains.conf format, with a hash to pass to ConfigDynamicApply.
+func (Admin) ConfigDynamicGet(ctx context.Context) admin.DynamicConfig {
+	dc, err := admin.DynamicConfigGet()
+	xcheckf(ctx, err, "get config")
+	return dc
+}
+
+// ConfigDynamicValidate returns the errors for the config as it would be
+// checked by ConfigDynamicApply. No errors means the config is valid.
+func (Admin) ConfigDynamicValidate(ctx context.Context, c config.Dynamic) []string {
+	errs := admin.DynamicConfigValidate(ctx, c)
+	l := make([]string, len(errs))
+	for i, err := range errs {
+		l[i] = err.Error()
+	}
+	return l
+}
+
+// ConfigDynamicDiff returns the changes to domains.conf that applying the config
+// would make, as unified diff. Empty if there are no changes.
+func (Admin) ConfigDynamicDiff(ctx context.Context, c config.Dynamic) string {
+	diff, err := admin.DynamicConfigDiff(c)
+	xcheckf(ctx, err, "diff config")
+	return diff
+}
+
+// ConfigDynamicApply validates the config, and writes and activates it.
+// baseHash must be the hash from ConfigDynamicGet for the config the changes are
+// based on, the call fails if the config has been changed since. If the written
+// domains.conf cannot be loaded, the previous config is restored. The new config
+// is returned.
+func (Admin) ConfigDynamicApply(ctx context.Context, baseHash string, c config.Dynamic) admin.DynamicConfig {
+	dc, err := admin.DynamicConfigApply(ctx, baseHash, c)
+	if errors.Is(err, admin.ErrConfigChanged) {
+		xcheckuserf(ctx, err, "apply config")
+	}
+	xcheckf(ctx, err, "apply config")
+	return dc
+}
+
 // AccountRoutesSave saves routes for an account.
 func (Admin) AccountRoutesSave(ctx context.Context, accountName string, routes []config.Route) {
 	err := admin.AccountSave(ctx, accountName, func(acc *config.Account) {
@@ -2505,6 +3384,75 @@ func (Admin) DomainRoutesSave(ctx context.Context, domainName string, routes []c
 	xcheckf(ctx, err, "saving domain routes")
 }
 
+// AccountOutgoingFooterSave saves the footer added to outgoing messages of an
+// account. A nil footer removes it.
+func (Admin) AccountOutgoingFooterSave(ctx context.Context, accountName string, footer *config.OutgoingFooter) {
+	err := admin.AccountSave(ctx, accountName, func(acc *config.Account) {
+		acc.OutgoingFooter = footer
+	})
+	xcheckf(ctx, err, "saving account outgoing footer")
+}
+
+// DomainOutgoingFooterSave saves the footer added to outgoing messages from a
+// domain. A nil footer removes it.
+func (Admin) DomainOutgoingFooterSave(ctx context.Context, domainName string, footer *config.OutgoingFooter) {
+	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
+		domain.OutgoingFooter = footer
+		return nil
+	})
+	xcheckf(ctx, err, "saving domain outgoing footer")
+}
+
+// AccountSpamActionsSave saves the spam actions of the junk filter of an
+// account. The account must have a junk filter. Zero values are unset, falling
+// back to the spam actions of the domain of the account.
+func (Admin) AccountSpamActionsSave(ctx context.Context, accountName string, spamActions config.SpamActions) {
+	conf, ok := mox.Conf.Account(accountName)
+	if !ok {
+		xcheckuserf(ctx, errors.New("no such account"), "looking up account")
+	} else if conf.JunkFilter == nil {
+		xcheckuserf(ctx, errors.New("account has no junk filter"), "saving spam actions")
+	}
+	err := admin.AccountSave(ctx, accountName, func(acc *config.Account) {
+		if acc.JunkFilter != nil {
+			acc.JunkFilter.SpamActions = spamActions
+		}
+	})
+	xcheckf(ctx, err, "saving account spam actions")
+}
+
+// DomainSpamActionsSave saves the default spam actions for accounts with the
+// domain as default domain. A nil value removes them.
+func (Admin) DomainSpamActionsSave(ctx context.Context, domainName string, spamActions *config.SpamActions) {
+	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
+		domain.SpamActions = spamActions
+		return nil
+	})
+	xcheckf(ctx, err, "saving domain spam actions")
+}
+
+// DomainSpamtrapsSave saves the localparts of spamtrap addresses of a domain.
+// Messages to spamtraps are not delivered, but update the server-wide sender
+// reputation and train the shared junk filter of the domain.
+func (Admin) DomainSpamtrapsSave(ctx context.Context, domainName string, localparts []string) {
+	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
+		domain.Spamtraps = localparts
+		return nil
+	})
+	xcheckf(ctx, err, "saving domain spamtraps")
+}
+
+// DomainMessageTemplatesSave saves the message templates of a domain, shared
+// with all accounts with an address in the domain for composing messages in the
+// webmail.
+func (Admin) DomainMessageTemplatesSave(ctx context.Context, domainName string, templates []config.MessageTemplate) {
+	err := admin.DomainSave(ctx, domainName, func(domain *config.Domain) error {
+		domain.MessageTemplates = templates
+		return nil
+	})
+	xcheckf(ctx, err, "saving domain message templates")
+}
+
 // RoutesSave saves global routes.
 func (Admin) RoutesSave(ctx context.Context, routes []config.Route) {
 	err := admin.ConfigSave(ctx, func(config *config.Dynamic) {
@@ -2661,6 +3609,25 @@ func (Admin) DomainDKIMAdd(ctx context.Context, domainName, selector, algorithm,
 	xcheckf(ctx, err, "adding dkim key")
 }
 
+// DomainDKIMRotation returns the state of automatic DKIM key rotation for a
+// domain, with DNS changes the administrator must make and the rotation history.
+func (Admin) DomainDKIMRotation(ctx context.Context, domainName string) admin.DKIMRotationStatus {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	st, err := admin.DKIMRotationInfo(ctx, d)
+	xcheckf(ctx, err, "get dkim rotation state")
+	return st
+}
+
+// DomainDKIMRecordsRemoved marks the DNS records of old DKIM selectors
+// removed by rotation as removed from DNS by the administrator.
+func (Admin) DomainDKIMRecordsRemoved(ctx context.Context, domainName string) {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	err = admin.DKIMRotationRecordsRemoved(ctx, d)
+	xcheckf(ctx, err, "marking dns records as removed")
+}
+
 // DomainDKIMRemove removes a DKIM selector for a domain.
 func (Admin) DomainDKIMRemove(ctx context.Context, domainName, selector string) {
 	d, err := dns.ParseDomain(domainName)
@@ -2774,6 +3741,65 @@ func (Admin) AliasAddressesRemove(ctx context.Context, aliaslp string, domainNam
 	xcheckf(ctx, err, "removing address from alias")
 }
 
+// AliasListSave saves the distribution list settings of an alias. PostPolicy is
+// one of "members", "anyone", "moderated", or empty to use PostPublic. Owners are
+// addresses of local accounts that can manage the members.
+func (Admin) AliasListSave(ctx context.Context, aliaslp string, domainName string, postPolicy string, listHeaders bool, owners []string) {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	err := admin.AliasListSave(ctx, addr, postPolicy, listHeaders, owners)
+	xcheckf(ctx, err, "saving alias list settings")
+}
+
+// AliasMailingListSave saves the mailing list settings of an alias. With a nil
+// list, the alias is no longer a mailing list.
+func (Admin) AliasMailingListSave(ctx context.Context, aliaslp string, domainName string, list *config.AliasList) {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	err := admin.AliasMailingListSave(ctx, addr, list)
+	xcheckf(ctx, err, "saving mailing list settings")
+}
+
+// ListSubscribers returns the subscribers of a mailing list, including those that
+// haven't confirmed their subscription yet.
+func (Admin) ListSubscribers(ctx context.Context, aliaslp string, domainName string) []mlist.Subscriber {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	l, err := mlist.Subscribers(ctx, addr.String())
+	xcheckf(ctx, err, "listing subscribers")
+	return l
+}
+
+// ListSubscriberAdd adds a confirmed subscriber to a mailing list.
+func (Admin) ListSubscriberAdd(ctx context.Context, aliaslp string, domainName string, address string, digest bool) {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	subAddr, err := smtp.ParseAddress(address)
+	xcheckuserf(ctx, err, "parsing address")
+	_, err = mlist.SubscriberAdd(ctx, addr.String(), subAddr, digest)
+	xcheckuserf(ctx, err, "adding subscriber")
+}
+
+// ListSubscriberRemove removes a subscriber from a mailing list.
+func (Admin) ListSubscriberRemove(ctx context.Context, aliaslp string, domainName string, subscriberID int64) {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	err := mlist.SubscriberRemove(ctx, addr.String(), subscriberID)
+	xcheckuserf(ctx, err, "removing subscriber")
+}
+
+// ListArchive returns the archived messages of a mailing list, most recent
+// first.
+func (Admin) ListArchive(ctx context.Context, aliaslp string, domainName string) []mlist.Message {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	l, err := mlist.Archive(ctx, addr.String())
+	xcheckf(ctx, err, "listing archive")
+	return l
+}
+
+// ListArchiveMessage returns an archived message of a mailing list as text.
+func (Admin) ListArchiveMessage(ctx context.Context, aliaslp string, domainName string, messageID int64) string {
+	addr := xparseAddress(ctx, aliaslp, domainName)
+	m, err := mlist.ArchiveMessage(ctx, addr.String(), messageID)
+	xcheckuserf(ctx, err, "get archived message")
+	return string(m.Data)
+}
+
 func (Admin) TLSPublicKeys(ctx context.Context, accountOpt string) ([]store.TLSPublicKey, error) {
 	return store.TLSPublicKeyList(ctx, accountOpt)
 }
@@ -2783,3 +3809,59 @@ func (Admin) LoginAttempts(ctx context.Context, accountName string, limit int) [
 	xcheckf(ctx, err, "listing login attempts")
 	return l
 }
+
+// AuditList returns events from the audit log matching filter, most recent first.
+// If limit is greater than 0, at most limit events are returned.
+func (Admin) AuditList(ctx context.Context, filter store.AuditFilter, limit int) []store.AuditEvent {
+	l, err := store.AuditList(ctx, filter, limit)
+	xcheckf(ctx, err, "listing audit events")
+	return l
+}
+
+// TLSCertsReload reloads the TLS certificates of listeners with static
+// keys/certificates or certificate sources, returning the names of the listeners
+// with reloaded certificates.
+func (Admin) TLSCertsReload(ctx context.Context) []string {
+	log := pkglog.WithContext(ctx)
+	reloaded, err := mox.ReloadTLSCerts(ctx, log)
+	xcheckf(ctx, err, "reloading tls certificates")
+	if reloaded == nil {
+		reloaded = []string{}
+	}
+	return reloaded
+}
+
+// StorageUsage returns the storage use of accounts relative to their quota,
+// largest first. If limit is greater than 0, at most limit accounts are returned.
+func (Admin) StorageUsage(ctx context.Context, limit int) []store.QuotaStatus {
+	log := pkglog.WithContext(ctx)
+
+	names := mox.Conf.Accounts()
+	if _, au := xadminUser(ctx); au.Role == config.AdminRoleDomainAdmin {
+		names = slices.DeleteFunc(names, func(name string) bool {
+			ac, ok := mox.Conf.Account(name)
+			return !ok || !slices.Contains(au.DNSDomains, ac.DNSDomain)
+		})
+	}
+
+	l := []store.QuotaStatus{}
+	for _, name := range names {
+		acc, err := store.OpenAccount(log, name, false)
+		xcheckf(ctx, err, "open account")
+		err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+			qs, err := acc.QuotaStatus(tx)
+			l = append(l, qs)
+			return err
+		})
+		cerr := acc.Close()
+		log.Check(cerr, "closing account")
+		xcheckf(ctx, err, "get quota status")
+	}
+	slices.SortFunc(l, func(a, b store.QuotaStatus) int {
+		return cmp.Compare(b.MessageSize, a.MessageSize)
+	})
+	if limit > 0 && len(l) > limit {
+		l = l[:limit]
+	}
+	return l
+}
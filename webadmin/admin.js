@@ -250,6 +250,14 @@ var api;
 		Mode["ModeTesting"] = "testing";
 		Mode["ModeNone"] = "none";
 	})(Mode = api.Mode || (api.Mode = {}));
+	// SignupState is the state of a signup request.
+	let SignupState;
+	(function (SignupState) {
+		SignupState["SignupUnverified"] = "unverified";
+		SignupState["SignupPending"] = "pending";
+		SignupState["SignupApproved"] = "approved";
+		SignupState["SignupRejected"] = "rejected";
+	})(SignupState = api.SignupState || (api.SignupState = {}));
 	// AuthResult is the result of a login attempt.
 	let AuthResult;
 	(function (AuthResult) {
@@ -260,13 +268,20 @@ var api;
 		AuthResult["AuthBadChannelBinding"] = "badchanbind";
 		AuthResult["AuthBadProtocol"] = "badprotocol";
 		AuthResult["AuthLoginDisabled"] = "logindisabled";
+		AuthResult["AuthLoginLocked"] = "loginlocked";
+		AuthResult["AuthPasswordExpired"] = "passwordexpired";
+		AuthResult["AuthAppPasswordRequired"] = "apppasswordrequired";
+		AuthResult["AuthTOTPRequired"] = "totprequired";
+		AuthResult["AuthTwoFactorSetup"] = "twofactorsetup";
 		AuthResult["AuthError"] = "error";
 		AuthResult["AuthAborted"] = "aborted";
 	})(AuthResult = api.AuthResult || (api.AuthResult = {}));
-	api.structTypes = { "Account": true, "Address": true, "AddressAlias": true, "Alias": true, "AliasAddress": true, "AuthResults": true, "AutoconfCheckResult": true, "AutodiscoverCheckResult": true, "AutodiscoverSRV": true, "AutomaticJunkFlags": true, "Canonicalization": true, "CheckResult": true, "ClientConfigs": true, "ClientConfigsEntry": true, "ConfigDomain": true, "DANECheckResult": true, "DKIM": true, "DKIMAuthResult": true, "DKIMCheckResult": true, "DKIMRecord": true, "DMARC": true, "DMARCCheckResult": true, "DMARCRecord": true, "DMARCSummary": true, "DNSSECResult": true, "DateRange": true, "Destination": true, "Directive": true, "Domain": true, "DomainFeedback": true, "Dynamic": true, "Evaluation": true, "EvaluationStat": true, "Extension": true, "FailureDetails": true, "Filter": true, "HoldRule": true, "Hook": true, "HookFilter": true, "HookResult": true, "HookRetired": true, "HookRetiredFilter": true, "HookRetiredSort": true, "HookSort": true, "IPDomain": true, "IPRevCheckResult": true, "Identifiers": true, "IncomingWebhook": true, "JunkFilter": true, "LoginAttempt": true, "MTASTS": true, "MTASTSCheckResult": true, "MTASTSRecord": true, "MX": true, "MXCheckResult": true, "Modifier": true, "Msg": true, "MsgResult": true, "MsgRetired": true, "OutgoingWebhook": true, "Pair": true, "Policy": true, "PolicyEvaluated": true, "PolicyOverrideReason": true, "PolicyPublished": true, "PolicyRecord": true, "Record": true, "Report": true, "ReportMetadata": true, "ReportRecord": true, "Result": true, "ResultPolicy": true, "RetiredFilter": true, "RetiredSort": true, "Reverse": true, "Route": true, "Row": true, "Ruleset": true, "SMTPAuth": true, "SPFAuthResult": true, "SPFCheckResult": true, "SPFRecord": true, "SRV": true, "SRVConfCheckResult": true, "STSMX": true, "Selector": true, "Sort": true, "SubjectPass": true, "Summary": true, "SuppressAddress": true, "TLSCheckResult": true, "TLSPublicKey": true, "TLSRPT": true, "TLSRPTCheckResult": true, "TLSRPTDateRange": true, "TLSRPTRecord": true, "TLSRPTSummary": true, "TLSRPTSuppressAddress": true, "TLSReportRecord": true, "TLSResult": true, "Transport": true, "TransportDirect": true, "TransportSMTP": true, "TransportSocks": true, "URI": true, "WebForward": true, "WebHandler": true, "WebInternal": true, "WebRedirect": true, "WebStatic": true, "WebserverConfig": true };
-	api.stringsTypes = { "Align": true, "AuthResult": true, "CSRFToken": true, "DMARCPolicy": true, "IP": true, "Localpart": true, "Mode": true, "RUA": true };
+	api.structTypes = { "Account": true, "AccountImportResult": true, "Address": true, "AddressAlias": true, "AdminUser": true, "Alias": true, "AliasAddress": true, "AliasList": true, "AliasMember": true, "Analysis": true, "AnalysisMechanism": true, "AnalysisRecord": true, "Analytics": true, "AnalyticsDay": true, "AnalyticsReporter": true, "AnalyticsSource": true, "AuditEvent": true, "AuditFilter": true, "AuthResults": true, "AutoconfCheckResult": true, "AutodiscoverCheckResult": true, "AutodiscoverSRV": true, "AutomaticJunkFlags": true, "BIMI": true, "Canonicalization": true, "Categories": true, "Category": true, "CheckResult": true, "ClientConfigs": true, "ClientConfigsEntry": true, "ConfigDomain": true, "DANECheckResult": true, "DANEHost": true, "DANEKey": true, "DKIM": true, "DKIMAuthResult": true, "DKIMCheckResult": true, "DKIMRecord": true, "DKIMRotation": true, "DKIMRotationEvent": true, "DKIMRotationStatus": true, "DKIMRotationTask": true, "DKIMSignRule": true, "DMARC": true, "DMARCCheckResult": true, "DMARCRecord": true, "DMARCSummary": true, "DNSProvisioning": true, "DNSSECResult": true, "DateRange": true, "DeadFilter": true, "Delegation": true, "Destination": true, "Directive": true, "Domain": true, "DomainFailureReport": true, "DomainFeedback": true, "DomainReadiness": true, "DomainSignup": true, "DomainWeb": true, "Dynamic": true, "DynamicConfig": true, "Evaluation": true, "EvaluationStat": true, "Extension": true, "FailureDetails": true, "FailureType": true, "Filter": true, "GoogleDeliveryError": true, "GoogleIPReputation": true, "GoogleStats": true, "HoldRule": true, "Hook": true, "HookFilter": true, "HookResult": true, "HookRetired": true, "HookRetiredFilter": true, "HookRetiredSort": true, "HookSort": true, "HostHealth": true, "IPDomain": true, "IPRevCheckResult": true, "Identifiers": true, "Identity": true, "IncomingWebhook": true, "JunkFilter": true, "JunkFilterStats": true, "JunkReevaluation": true, "JunkStats": true, "JunkVerdict": true, "LoginAttempt": true, "Lookup": true, "MTASTS": true, "MTASTSCheckResult": true, "MTASTSRecord": true, "MTASTSRollout": true, "MX": true, "MXCheckResult": true, "Message": true, "MessageJunkVerdict": true, "MessageJunkWord": true, "MessageStorage": true, "MessageTemplate": true, "Modifier": true, "Msg": true, "MsgChange": true, "MsgDead": true, "MsgResult": true, "MsgRetired": true, "MsgRewrite": true, "OutgoingFooter": true, "OutgoingHeaderRules": true, "OutgoingWebhook": true, "Pair": true, "Passkey": true, "PasskeyAssertion": true, "PasskeyCreateOptions": true, "PasskeyGetOptions": true, "PasswordPolicy": true, "Policy": true, "PolicyEvaluated": true, "PolicyOverrideReason": true, "PolicyPublished": true, "PolicyRecord": true, "PostmasterData": true, "QuotaStatus": true, "ReadinessItem": true, "Record": true, "Report": true, "ReportMetadata": true, "ReportRecord": true, "Result": true, "ResultPolicy": true, "RetiredFilter": true, "RetiredSort": true, "RetrySchedule": true, "Reverse": true, "Route": true, "Row": true, "Ruleset": true, "SMTPAuth": true, "SNDSRecord": true, "SPFAuthResult": true, "SPFCheckResult": true, "SPFRecord": true, "SRV": true, "SRVConfCheckResult": true, "STSMX": true, "Screening": true, "Selector": true, "SendSuspension": true, "Sender": true, "SharedJunkFilter": true, "SignupInvite": true, "SignupRequest": true, "Sort": true, "SpamActions": true, "Stats": true, "StatsCategory": true, "StatsDay": true, "StatsReporter": true, "StatsResultType": true, "SubjectPass": true, "Subscriber": true, "Summary": true, "SuppressAddress": true, "TLSCheckResult": true, "TLSPolicy": true, "TLSPublicKey": true, "TLSRPT": true, "TLSRPTCheckResult": true, "TLSRPTDateRange": true, "TLSRPTRecord": true, "TLSRPTSummary": true, "TLSRPTSuppressAddress": true, "TLSReportRecord": true, "TLSResult": true, "TOTPSetup": true, "Transport": true, "TransportDirect": true, "TransportSMTP": true, "TransportSocks": true, "URI": true, "Volume": true, "WebForward": true, "WebHandler": true, "WebInternal": true, "WebRedirect": true, "WebStatic": true, "WebmailDefaults": true, "WebserverConfig": true };
+	api.stringsTypes = { "Align": true, "AuthResult": true, "CSRFToken": true, "DMARCPolicy": true, "FailureCategory": true, "IP": true, "Localpart": true, "Mode": true, "Override": true, "RUA": true, "SenderType": true, "SignupState": true, "Status": true };
 	api.intsTypes = {};
 	api.types = {
+		"PasskeyGetOptions": { "Name": "PasskeyGetOptions", "Docs": "", "Fields": [{ "Name": "Challenge", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "RPID", "Docs": "", "Typewords": ["string"] }, { "Name": "Timeout", "Docs": "", "Typewords": ["int32"] }] },
+		"PasskeyAssertion": { "Name": "PasskeyAssertion", "Docs": "", "Fields": [{ "Name": "CredentialID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ClientDataJSON", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "AuthenticatorData", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["nullable", "string"] }] },
 		"CheckResult": { "Name": "CheckResult", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "DNSSEC", "Docs": "", "Typewords": ["DNSSECResult"] }, { "Name": "IPRev", "Docs": "", "Typewords": ["IPRevCheckResult"] }, { "Name": "MX", "Docs": "", "Typewords": ["MXCheckResult"] }, { "Name": "TLS", "Docs": "", "Typewords": ["TLSCheckResult"] }, { "Name": "DANE", "Docs": "", "Typewords": ["DANECheckResult"] }, { "Name": "SPF", "Docs": "", "Typewords": ["SPFCheckResult"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["DKIMCheckResult"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["DMARCCheckResult"] }, { "Name": "HostTLSRPT", "Docs": "", "Typewords": ["TLSRPTCheckResult"] }, { "Name": "DomainTLSRPT", "Docs": "", "Typewords": ["TLSRPTCheckResult"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["MTASTSCheckResult"] }, { "Name": "SRVConf", "Docs": "", "Typewords": ["SRVConfCheckResult"] }, { "Name": "Autoconf", "Docs": "", "Typewords": ["AutoconfCheckResult"] }, { "Name": "Autodiscover", "Docs": "", "Typewords": ["AutodiscoverCheckResult"] }] },
 		"DNSSECResult": { "Name": "DNSSECResult", "Docs": "", "Fields": [{ "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Instructions", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"IPRevCheckResult": { "Name": "IPRevCheckResult", "Docs": "", "Fields": [{ "Name": "Hostname", "Docs": "", "Typewords": ["Domain"] }, { "Name": "IPNames", "Docs": "", "Typewords": ["{}", "[]", "string"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Instructions", "Docs": "", "Typewords": ["[]", "string"] }] },
@@ -298,26 +313,47 @@ var api;
 		"AutoconfCheckResult": { "Name": "AutoconfCheckResult", "Docs": "", "Fields": [{ "Name": "ClientSettingsDomainIPs", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "IPs", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Instructions", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"AutodiscoverCheckResult": { "Name": "AutodiscoverCheckResult", "Docs": "", "Fields": [{ "Name": "Records", "Docs": "", "Typewords": ["[]", "AutodiscoverSRV"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Instructions", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"AutodiscoverSRV": { "Name": "AutodiscoverSRV", "Docs": "", "Fields": [{ "Name": "Target", "Docs": "", "Typewords": ["string"] }, { "Name": "Port", "Docs": "", "Typewords": ["uint16"] }, { "Name": "Priority", "Docs": "", "Typewords": ["uint16"] }, { "Name": "Weight", "Docs": "", "Typewords": ["uint16"] }, { "Name": "IPs", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"ConfigDomain": { "Name": "ConfigDomain", "Docs": "", "Fields": [{ "Name": "Disabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "ClientSettingsDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "LocalpartCatchallSeparator", "Docs": "", "Typewords": ["string"] }, { "Name": "LocalpartCatchallSeparators", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "LocalpartCaseSensitive", "Docs": "", "Typewords": ["bool"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["DKIM"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["nullable", "DMARC"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["nullable", "MTASTS"] }, { "Name": "TLSRPT", "Docs": "", "Typewords": ["nullable", "TLSRPT"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "Aliases", "Docs": "", "Typewords": ["{}", "Alias"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "LocalpartCatchallSeparatorsEffective", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"DKIM": { "Name": "DKIM", "Docs": "", "Fields": [{ "Name": "Selectors", "Docs": "", "Typewords": ["{}", "Selector"] }, { "Name": "Sign", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"ConfigDomain": { "Name": "ConfigDomain", "Docs": "", "Fields": [{ "Name": "Disabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "ClientSettingsDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "LocalpartCatchallSeparator", "Docs": "", "Typewords": ["string"] }, { "Name": "LocalpartCatchallSeparators", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "LocalpartCaseSensitive", "Docs": "", "Typewords": ["bool"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["DKIM"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["nullable", "DMARC"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["nullable", "MTASTS"] }, { "Name": "TLSRPT", "Docs": "", "Typewords": ["nullable", "TLSRPT"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "Aliases", "Docs": "", "Typewords": ["{}", "Alias"] }, { "Name": "OutgoingHeaderRules", "Docs": "", "Typewords": ["nullable", "OutgoingHeaderRules"] }, { "Name": "OutgoingFooter", "Docs": "", "Typewords": ["nullable", "OutgoingFooter"] }, { "Name": "MessageTemplates", "Docs": "", "Typewords": ["[]", "MessageTemplate"] }, { "Name": "RequireTwoFactor", "Docs": "", "Typewords": ["bool"] }, { "Name": "QuotaMessageSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "DNSProvisioning", "Docs": "", "Typewords": ["nullable", "DNSProvisioning"] }, { "Name": "Signup", "Docs": "", "Typewords": ["nullable", "DomainSignup"] }, { "Name": "Web", "Docs": "", "Typewords": ["nullable", "DomainWeb"] }, { "Name": "BIMI", "Docs": "", "Typewords": ["nullable", "BIMI"] }, { "Name": "SpamActions", "Docs": "", "Typewords": ["nullable", "SpamActions"] }, { "Name": "SharedJunkFilter", "Docs": "", "Typewords": ["nullable", "SharedJunkFilter"] }, { "Name": "Spamtraps", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "LocalpartCatchallSeparatorsEffective", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"DKIM": { "Name": "DKIM", "Docs": "", "Fields": [{ "Name": "Selectors", "Docs": "", "Typewords": ["{}", "Selector"] }, { "Name": "Sign", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignRules", "Docs": "", "Typewords": ["[]", "DKIMSignRule"] }, { "Name": "Rotation", "Docs": "", "Typewords": ["nullable", "DKIMRotation"] }] },
 		"Selector": { "Name": "Selector", "Docs": "", "Fields": [{ "Name": "Hash", "Docs": "", "Typewords": ["string"] }, { "Name": "HashEffective", "Docs": "", "Typewords": ["string"] }, { "Name": "Canonicalization", "Docs": "", "Typewords": ["Canonicalization"] }, { "Name": "Headers", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HeadersEffective", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "DontSealHeaders", "Docs": "", "Typewords": ["bool"] }, { "Name": "Expiration", "Docs": "", "Typewords": ["string"] }, { "Name": "PrivateKeyFile", "Docs": "", "Typewords": ["string"] }, { "Name": "Algorithm", "Docs": "", "Typewords": ["string"] }] },
 		"Canonicalization": { "Name": "Canonicalization", "Docs": "", "Fields": [{ "Name": "HeaderRelaxed", "Docs": "", "Typewords": ["bool"] }, { "Name": "BodyRelaxed", "Docs": "", "Typewords": ["bool"] }] },
-		"DMARC": { "Name": "DMARC", "Docs": "", "Fields": [{ "Name": "Localpart", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "ParsedLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
+		"DKIMSignRule": { "Name": "DKIMSignRule", "Docs": "", "Fields": [{ "Name": "MsgFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "HeadersRegexp", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Sign", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }] },
+		"DKIMRotation": { "Name": "DKIMRotation", "Docs": "", "Fields": [{ "Name": "Interval", "Docs": "", "Typewords": ["int64"] }, { "Name": "PublishDelay", "Docs": "", "Typewords": ["int64"] }, { "Name": "RetainPeriod", "Docs": "", "Typewords": ["int64"] }] },
+		"DMARC": { "Name": "DMARC", "Docs": "", "Fields": [{ "Name": "Localpart", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "FailureAlertPercentage", "Docs": "", "Typewords": ["int32"] }, { "Name": "ParsedLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
 		"MTASTS": { "Name": "MTASTS", "Docs": "", "Fields": [{ "Name": "PolicyID", "Docs": "", "Typewords": ["string"] }, { "Name": "Mode", "Docs": "", "Typewords": ["Mode"] }, { "Name": "MaxAge", "Docs": "", "Typewords": ["int64"] }, { "Name": "MX", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"TLSRPT": { "Name": "TLSRPT", "Docs": "", "Fields": [{ "Name": "Localpart", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "ParsedLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
 		"Route": { "Name": "Route", "Docs": "", "Fields": [{ "Name": "FromDomain", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ToDomain", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MinimumAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "FromDomainASCII", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ToDomainASCII", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"Alias": { "Name": "Alias", "Docs": "", "Fields": [{ "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PostPublic", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListMembers", "Docs": "", "Typewords": ["bool"] }, { "Name": "AllowMsgFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "LocalpartStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ParsedAddresses", "Docs": "", "Typewords": ["[]", "AliasAddress"] }] },
-		"AliasAddress": { "Name": "AliasAddress", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["Address"] }, { "Name": "AccountName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destination", "Docs": "", "Typewords": ["Destination"] }] },
+		"Alias": { "Name": "Alias", "Docs": "", "Fields": [{ "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PostPublic", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListMembers", "Docs": "", "Typewords": ["bool"] }, { "Name": "AllowMsgFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "PostPolicy", "Docs": "", "Typewords": ["string"] }, { "Name": "Owners", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ListHeaders", "Docs": "", "Typewords": ["bool"] }, { "Name": "Members", "Docs": "", "Typewords": ["{}", "AliasMember"] }, { "Name": "List", "Docs": "", "Typewords": ["nullable", "AliasList"] }, { "Name": "LocalpartStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ParsedAddresses", "Docs": "", "Typewords": ["[]", "AliasAddress"] }, { "Name": "ParsedOwners", "Docs": "", "Typewords": ["[]", "AliasAddress"] }] },
+		"AliasMember": { "Name": "AliasMember", "Docs": "", "Fields": [{ "Name": "NoDelivery", "Docs": "", "Typewords": ["bool"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }] },
+		"AliasList": { "Name": "AliasList", "Docs": "", "Fields": [{ "Name": "Subscribe", "Docs": "", "Typewords": ["bool"] }, { "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "BounceLimit", "Docs": "", "Typewords": ["int32"] }] },
+		"AliasAddress": { "Name": "AliasAddress", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["Address"] }, { "Name": "AccountName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destination", "Docs": "", "Typewords": ["Destination"] }, { "Name": "Member", "Docs": "", "Typewords": ["AliasMember"] }] },
 		"Address": { "Name": "Address", "Docs": "", "Fields": [{ "Name": "Localpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }] },
 		"Destination": { "Name": "Destination", "Docs": "", "Fields": [{ "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Rulesets", "Docs": "", "Typewords": ["[]", "Ruleset"] }, { "Name": "SMTPError", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageAuthRequiredSMTPError", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }] },
 		"Ruleset": { "Name": "Ruleset", "Docs": "", "Fields": [{ "Name": "SMTPMailFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "HeadersRegexp", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListAllowDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "AcceptRejectsToMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ListAllowDNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
-		"Account": { "Name": "Account", "Docs": "", "Fields": [{ "Name": "OutgoingWebhook", "Docs": "", "Typewords": ["nullable", "OutgoingWebhook"] }, { "Name": "IncomingWebhook", "Docs": "", "Typewords": ["nullable", "IncomingWebhook"] }, { "Name": "FromIDLoginAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "KeepRetiredMessagePeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "KeepRetiredWebhookPeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginDisabled", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destinations", "Docs": "", "Typewords": ["{}", "Destination"] }, { "Name": "SubjectPass", "Docs": "", "Typewords": ["SubjectPass"] }, { "Name": "QuotaMessageSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "KeepRejects", "Docs": "", "Typewords": ["bool"] }, { "Name": "AutomaticJunkFlags", "Docs": "", "Typewords": ["AutomaticJunkFlags"] }, { "Name": "JunkFilter", "Docs": "", "Typewords": ["nullable", "JunkFilter"] }, { "Name": "MaxOutgoingMessagesPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxFirstTimeRecipientsPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "NoFirstTimeSenderDelay", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoCustomPassword", "Docs": "", "Typewords": ["bool"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Aliases", "Docs": "", "Typewords": ["[]", "AddressAlias"] }] },
-		"OutgoingWebhook": { "Name": "OutgoingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "Events", "Docs": "", "Typewords": ["[]", "string"] }] },
-		"IncomingWebhook": { "Name": "IncomingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }] },
+		"OutgoingHeaderRules": { "Name": "OutgoingHeaderRules", "Docs": "", "Fields": [{ "Name": "Remove", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "FromDisplayName", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Add", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"OutgoingFooter": { "Name": "OutgoingFooter", "Docs": "", "Fields": [{ "Name": "Text", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HTML", "Docs": "", "Typewords": ["string"] }] },
+		"MessageTemplate": { "Name": "MessageTemplate", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"DNSProvisioning": { "Name": "DNSProvisioning", "Docs": "", "Fields": [{ "Name": "Provider", "Docs": "", "Typewords": ["string"] }, { "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "TTL", "Docs": "", "Typewords": ["int32"] }, { "Name": "DKIMRotation", "Docs": "", "Typewords": ["nullable", "DKIMRotation"] }] },
+		"DomainSignup": { "Name": "DomainSignup", "Docs": "", "Fields": [{ "Name": "Open", "Docs": "", "Typewords": ["bool"] }, { "Name": "RequireApproval", "Docs": "", "Typewords": ["bool"] }, { "Name": "QuotaMessageSize", "Docs": "", "Typewords": ["int64"] }] },
+		"DomainWeb": { "Name": "DomainWeb", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "LogoFile", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginText", "Docs": "", "Typewords": ["string"] }, { "Name": "Color", "Docs": "", "Typewords": ["string"] }, { "Name": "BackgroundColor", "Docs": "", "Typewords": ["string"] }, { "Name": "CSSFile", "Docs": "", "Typewords": ["string"] }, { "Name": "WebmailDefaults", "Docs": "", "Typewords": ["nullable", "WebmailDefaults"] }, { "Name": "DisabledFeatures", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"WebmailDefaults": { "Name": "WebmailDefaults", "Docs": "", "Fields": [{ "Name": "ShowHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowAddressSecurity", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoShowShortcuts", "Docs": "", "Typewords": ["bool"] }, { "Name": "RemoteContentProxy", "Docs": "", "Typewords": ["bool"] }, { "Name": "UndoSendSeconds", "Docs": "", "Typewords": ["int32"] }] },
+		"BIMI": { "Name": "BIMI", "Docs": "", "Fields": [{ "Name": "Selector", "Docs": "", "Typewords": ["string"] }, { "Name": "LogoFile", "Docs": "", "Typewords": ["string"] }, { "Name": "LogoURL", "Docs": "", "Typewords": ["string"] }, { "Name": "AuthorityFile", "Docs": "", "Typewords": ["string"] }, { "Name": "AuthorityURL", "Docs": "", "Typewords": ["string"] }] },
+		"SpamActions": { "Name": "SpamActions", "Docs": "", "Fields": [{ "Name": "TagThreshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "JunkThreshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "HighAction", "Docs": "", "Typewords": ["string"] }] },
+		"SharedJunkFilter": { "Name": "SharedJunkFilter", "Docs": "", "Fields": [{ "Name": "Weight", "Docs": "", "Typewords": ["float64"] }, { "Name": "Onegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "Twograms", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "MaxPower", "Docs": "", "Typewords": ["float64"] }, { "Name": "TopWords", "Docs": "", "Typewords": ["int32"] }, { "Name": "IgnoreWords", "Docs": "", "Typewords": ["float64"] }, { "Name": "RareWords", "Docs": "", "Typewords": ["int32"] }] },
+		"Account": { "Name": "Account", "Docs": "", "Fields": [{ "Name": "OutgoingWebhook", "Docs": "", "Typewords": ["nullable", "OutgoingWebhook"] }, { "Name": "IncomingWebhook", "Docs": "", "Typewords": ["nullable", "IncomingWebhook"] }, { "Name": "FromIDLoginAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "KeepRetiredMessagePeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "KeepRetiredWebhookPeriod", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginDisabled", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }, { "Name": "Destinations", "Docs": "", "Typewords": ["{}", "Destination"] }, { "Name": "SubjectPass", "Docs": "", "Typewords": ["SubjectPass"] }, { "Name": "QuotaMessageSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "KeepRejects", "Docs": "", "Typewords": ["bool"] }, { "Name": "AutomaticJunkFlags", "Docs": "", "Typewords": ["AutomaticJunkFlags"] }, { "Name": "JunkFilter", "Docs": "", "Typewords": ["nullable", "JunkFilter"] }, { "Name": "JunkReevaluation", "Docs": "", "Typewords": ["nullable", "JunkReevaluation"] }, { "Name": "Screening", "Docs": "", "Typewords": ["nullable", "Screening"] }, { "Name": "Categories", "Docs": "", "Typewords": ["nullable", "Categories"] }, { "Name": "MaxOutgoingMessagesPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxFirstTimeRecipientsPerDay", "Docs": "", "Typewords": ["int32"] }, { "Name": "NoFirstTimeSenderDelay", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoCustomPassword", "Docs": "", "Typewords": ["bool"] }, { "Name": "PasswordPolicy", "Docs": "", "Typewords": ["nullable", "PasswordPolicy"] }, { "Name": "RequireTwoFactor", "Docs": "", "Typewords": ["bool"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "OutgoingHeaderRules", "Docs": "", "Typewords": ["nullable", "OutgoingHeaderRules"] }, { "Name": "OutgoingFooter", "Docs": "", "Typewords": ["nullable", "OutgoingFooter"] }, { "Name": "Identities", "Docs": "", "Typewords": ["[]", "Identity"] }, { "Name": "Delegations", "Docs": "", "Typewords": ["[]", "Delegation"] }, { "Name": "DNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Aliases", "Docs": "", "Typewords": ["[]", "AddressAlias"] }, { "Name": "OwnedAliases", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"OutgoingWebhook": { "Name": "OutgoingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "Events", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SigningKey", "Docs": "", "Typewords": ["string"] }, { "Name": "Transcript", "Docs": "", "Typewords": ["bool"] }] },
+		"IncomingWebhook": { "Name": "IncomingWebhook", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "SigningKey", "Docs": "", "Typewords": ["string"] }] },
 		"SubjectPass": { "Name": "SubjectPass", "Docs": "", "Fields": [{ "Name": "Period", "Docs": "", "Typewords": ["int64"] }] },
 		"AutomaticJunkFlags": { "Name": "AutomaticJunkFlags", "Docs": "", "Fields": [{ "Name": "Enabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "JunkMailboxRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "NeutralMailboxRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "NotJunkMailboxRegexp", "Docs": "", "Typewords": ["string"] }] },
-		"JunkFilter": { "Name": "JunkFilter", "Docs": "", "Fields": [{ "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "Onegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "Twograms", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "MaxPower", "Docs": "", "Typewords": ["float64"] }, { "Name": "TopWords", "Docs": "", "Typewords": ["int32"] }, { "Name": "IgnoreWords", "Docs": "", "Typewords": ["float64"] }, { "Name": "RareWords", "Docs": "", "Typewords": ["int32"] }] },
-		"AddressAlias": { "Name": "AddressAlias", "Docs": "", "Fields": [{ "Name": "SubscriptionAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Alias", "Docs": "", "Typewords": ["Alias"] }, { "Name": "MemberAddresses", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"JunkFilter": { "Name": "JunkFilter", "Docs": "", "Fields": [{ "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "TagThreshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "JunkThreshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "HighAction", "Docs": "", "Typewords": ["string"] }, { "Name": "Onegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "Twograms", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threegrams", "Docs": "", "Typewords": ["bool"] }, { "Name": "MaxPower", "Docs": "", "Typewords": ["float64"] }, { "Name": "TopWords", "Docs": "", "Typewords": ["int32"] }, { "Name": "IgnoreWords", "Docs": "", "Typewords": ["float64"] }, { "Name": "RareWords", "Docs": "", "Typewords": ["int32"] }] },
+		"JunkReevaluation": { "Name": "JunkReevaluation", "Docs": "", "Fields": [{ "Name": "Window", "Docs": "", "Typewords": ["int64"] }, { "Name": "Sender", "Docs": "", "Typewords": ["bool"] }, { "Name": "DNSBL", "Docs": "", "Typewords": ["bool"] }, { "Name": "Spamtrap", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notify", "Docs": "", "Typewords": ["bool"] }] },
+		"Screening": { "Name": "Screening", "Docs": "", "Fields": [{ "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }] },
+		"Categories": { "Name": "Categories", "Docs": "", "Fields": [{ "Name": "Newsletters", "Docs": "", "Typewords": ["nullable", "Category"] }, { "Name": "Notifications", "Docs": "", "Typewords": ["nullable", "Category"] }, { "Name": "Social", "Docs": "", "Typewords": ["nullable", "Category"] }] },
+		"Category": { "Name": "Category", "Docs": "", "Fields": [{ "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Domains", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"PasswordPolicy": { "Name": "PasswordPolicy", "Docs": "", "Fields": [{ "Name": "MinLength", "Docs": "", "Typewords": ["int32"] }, { "Name": "MinEntropy", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAge", "Docs": "", "Typewords": ["int64"] }, { "Name": "LockoutFailures", "Docs": "", "Typewords": ["int32"] }, { "Name": "LockoutPeriod", "Docs": "", "Typewords": ["int64"] }] },
+		"Identity": { "Name": "Identity", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "DisplayName", "Docs": "", "Typewords": ["string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignatureHTML", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyQuoting", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Default", "Docs": "", "Typewords": ["bool"] }] },
+		"Delegation": { "Name": "Delegation", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Write", "Docs": "", "Typewords": ["bool"] }, { "Name": "Send", "Docs": "", "Typewords": ["string"] }] },
+		"AddressAlias": { "Name": "AddressAlias", "Docs": "", "Fields": [{ "Name": "SubscriptionAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Alias", "Docs": "", "Typewords": ["Alias"] }, { "Name": "MemberAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Member", "Docs": "", "Typewords": ["AliasMember"] }] },
 		"PolicyRecord": { "Name": "PolicyRecord", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Inserted", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ValidEnd", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastUpdate", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastUse", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Backoff", "Docs": "", "Typewords": ["bool"] }, { "Name": "RecordID", "Docs": "", "Typewords": ["string"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }, { "Name": "Mode", "Docs": "", "Typewords": ["Mode"] }, { "Name": "MX", "Docs": "", "Typewords": ["[]", "STSMX"] }, { "Name": "MaxAgeSeconds", "Docs": "", "Typewords": ["int32"] }, { "Name": "Extensions", "Docs": "", "Typewords": ["[]", "Pair"] }, { "Name": "PolicyText", "Docs": "", "Typewords": ["string"] }] },
 		"TLSReportRecord": { "Name": "TLSReportRecord", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "FromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "HostReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "Report", "Docs": "", "Typewords": ["Report"] }] },
 		"Report": { "Name": "Report", "Docs": "", "Fields": [{ "Name": "OrganizationName", "Docs": "", "Typewords": ["string"] }, { "Name": "DateRange", "Docs": "", "Typewords": ["TLSRPTDateRange"] }, { "Name": "ContactInfo", "Docs": "", "Typewords": ["string"] }, { "Name": "ReportID", "Docs": "", "Typewords": ["string"] }, { "Name": "Policies", "Docs": "", "Typewords": ["[]", "Result"] }] },
@@ -327,6 +363,12 @@ var api;
 		"Summary": { "Name": "Summary", "Docs": "", "Fields": [{ "Name": "TotalSuccessfulSessionCount", "Docs": "", "Typewords": ["int64"] }, { "Name": "TotalFailureSessionCount", "Docs": "", "Typewords": ["int64"] }] },
 		"FailureDetails": { "Name": "FailureDetails", "Docs": "", "Fields": [{ "Name": "ResultType", "Docs": "", "Typewords": ["string"] }, { "Name": "SendingMTAIP", "Docs": "", "Typewords": ["string"] }, { "Name": "ReceivingMXHostname", "Docs": "", "Typewords": ["string"] }, { "Name": "ReceivingMXHelo", "Docs": "", "Typewords": ["string"] }, { "Name": "ReceivingIP", "Docs": "", "Typewords": ["string"] }, { "Name": "FailedSessionCount", "Docs": "", "Typewords": ["int64"] }, { "Name": "AdditionalInformation", "Docs": "", "Typewords": ["string"] }, { "Name": "FailureReasonCode", "Docs": "", "Typewords": ["string"] }] },
 		"TLSRPTSummary": { "Name": "TLSRPTSummary", "Docs": "", "Fields": [{ "Name": "PolicyDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Success", "Docs": "", "Typewords": ["int64"] }, { "Name": "Failure", "Docs": "", "Typewords": ["int64"] }, { "Name": "ResultTypeCounts", "Docs": "", "Typewords": ["{}", "int64"] }] },
+		"Stats": { "Name": "Stats", "Docs": "", "Fields": [{ "Name": "PolicyDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "Reports", "Docs": "", "Typewords": ["int32"] }, { "Name": "Success", "Docs": "", "Typewords": ["int64"] }, { "Name": "Failure", "Docs": "", "Typewords": ["int64"] }, { "Name": "Categories", "Docs": "", "Typewords": ["[]", "StatsCategory"] }, { "Name": "ResultTypes", "Docs": "", "Typewords": ["[]", "StatsResultType"] }, { "Name": "Days", "Docs": "", "Typewords": ["[]", "StatsDay"] }, { "Name": "Reporters", "Docs": "", "Typewords": ["[]", "StatsReporter"] }] },
+		"StatsCategory": { "Name": "StatsCategory", "Docs": "", "Fields": [{ "Name": "Category", "Docs": "", "Typewords": ["FailureCategory"] }, { "Name": "Failure", "Docs": "", "Typewords": ["int64"] }] },
+		"StatsResultType": { "Name": "StatsResultType", "Docs": "", "Fields": [{ "Name": "ResultType", "Docs": "", "Typewords": ["string"] }, { "Name": "Category", "Docs": "", "Typewords": ["FailureCategory"] }, { "Name": "Failure", "Docs": "", "Typewords": ["int64"] }, { "Name": "Reports", "Docs": "", "Typewords": ["int32"] }, { "Name": "MXHosts", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Reasons", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"StatsDay": { "Name": "StatsDay", "Docs": "", "Fields": [{ "Name": "Day", "Docs": "", "Typewords": ["string"] }, { "Name": "Success", "Docs": "", "Typewords": ["int64"] }, { "Name": "Failure", "Docs": "", "Typewords": ["int64"] }] },
+		"StatsReporter": { "Name": "StatsReporter", "Docs": "", "Fields": [{ "Name": "OrganizationName", "Docs": "", "Typewords": ["string"] }, { "Name": "Reports", "Docs": "", "Typewords": ["int32"] }, { "Name": "Success", "Docs": "", "Typewords": ["int64"] }, { "Name": "Failure", "Docs": "", "Typewords": ["int64"] }] },
+		"FailureType": { "Name": "FailureType", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "PolicyDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "ResultType", "Docs": "", "Typewords": ["string"] }, { "Name": "First", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Last", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Sessions", "Docs": "", "Typewords": ["int64"] }] },
 		"DomainFeedback": { "Name": "DomainFeedback", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "FromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }, { "Name": "ReportMetadata", "Docs": "", "Typewords": ["ReportMetadata"] }, { "Name": "PolicyPublished", "Docs": "", "Typewords": ["PolicyPublished"] }, { "Name": "Records", "Docs": "", "Typewords": ["[]", "ReportRecord"] }] },
 		"ReportMetadata": { "Name": "ReportMetadata", "Docs": "", "Fields": [{ "Name": "OrgName", "Docs": "", "Typewords": ["string"] }, { "Name": "Email", "Docs": "", "Typewords": ["string"] }, { "Name": "ExtraContactInfo", "Docs": "", "Typewords": ["string"] }, { "Name": "ReportID", "Docs": "", "Typewords": ["string"] }, { "Name": "DateRange", "Docs": "", "Typewords": ["DateRange"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"DateRange": { "Name": "DateRange", "Docs": "", "Fields": [{ "Name": "Begin", "Docs": "", "Typewords": ["int64"] }, { "Name": "End", "Docs": "", "Typewords": ["int64"] }] },
@@ -339,19 +381,56 @@ var api;
 		"AuthResults": { "Name": "AuthResults", "Docs": "", "Fields": [{ "Name": "DKIM", "Docs": "", "Typewords": ["[]", "DKIMAuthResult"] }, { "Name": "SPF", "Docs": "", "Typewords": ["[]", "SPFAuthResult"] }] },
 		"DKIMAuthResult": { "Name": "DKIMAuthResult", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Selector", "Docs": "", "Typewords": ["string"] }, { "Name": "Result", "Docs": "", "Typewords": ["string"] }, { "Name": "HumanResult", "Docs": "", "Typewords": ["string"] }] },
 		"SPFAuthResult": { "Name": "SPFAuthResult", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Scope", "Docs": "", "Typewords": ["string"] }, { "Name": "Result", "Docs": "", "Typewords": ["string"] }] },
+		"DomainFailureReport": { "Name": "DomainFailureReport", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "FromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "FeedbackType", "Docs": "", "Typewords": ["string"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }, { "Name": "OriginalMailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "OriginalRcptTo", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ArrivalDate", "Docs": "", "Typewords": ["string"] }, { "Name": "SourceIP", "Docs": "", "Typewords": ["string"] }, { "Name": "ReportedDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "AuthenticationResults", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "AuthFailure", "Docs": "", "Typewords": ["string"] }, { "Name": "IdentityAlignment", "Docs": "", "Typewords": ["string"] }, { "Name": "DeliveryResult", "Docs": "", "Typewords": ["string"] }, { "Name": "DKIMDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "DKIMIdentity", "Docs": "", "Typewords": ["string"] }, { "Name": "DKIMSelector", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFDNS", "Docs": "", "Typewords": ["string"] }, { "Name": "Header", "Docs": "", "Typewords": ["string"] }] },
 		"DMARCSummary": { "Name": "DMARCSummary", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "DispositionNone", "Docs": "", "Typewords": ["int32"] }, { "Name": "DispositionQuarantine", "Docs": "", "Typewords": ["int32"] }, { "Name": "DispositionReject", "Docs": "", "Typewords": ["int32"] }, { "Name": "DKIMFail", "Docs": "", "Typewords": ["int32"] }, { "Name": "SPFFail", "Docs": "", "Typewords": ["int32"] }, { "Name": "PolicyOverrides", "Docs": "", "Typewords": ["{}", "int32"] }] },
+		"Analytics": { "Name": "Analytics", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Reports", "Docs": "", "Typewords": ["int32"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "Pass", "Docs": "", "Typewords": ["int32"] }, { "Name": "Fail", "Docs": "", "Typewords": ["int32"] }, { "Name": "DKIMAlignedPass", "Docs": "", "Typewords": ["int32"] }, { "Name": "SPFAlignedPass", "Docs": "", "Typewords": ["int32"] }, { "Name": "DKIMAuthPass", "Docs": "", "Typewords": ["int32"] }, { "Name": "SPFAuthPass", "Docs": "", "Typewords": ["int32"] }, { "Name": "Quarantine", "Docs": "", "Typewords": ["int32"] }, { "Name": "Reject", "Docs": "", "Typewords": ["int32"] }, { "Name": "Days", "Docs": "", "Typewords": ["[]", "AnalyticsDay"] }, { "Name": "Sources", "Docs": "", "Typewords": ["[]", "AnalyticsSource"] }, { "Name": "FailingSources", "Docs": "", "Typewords": ["[]", "AnalyticsSource"] }, { "Name": "Reporters", "Docs": "", "Typewords": ["[]", "AnalyticsReporter"] }] },
+		"AnalyticsDay": { "Name": "AnalyticsDay", "Docs": "", "Fields": [{ "Name": "Day", "Docs": "", "Typewords": ["string"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "Fail", "Docs": "", "Typewords": ["int32"] }, { "Name": "DKIMFail", "Docs": "", "Typewords": ["int32"] }, { "Name": "SPFFail", "Docs": "", "Typewords": ["int32"] }] },
+		"AnalyticsSource": { "Name": "AnalyticsSource", "Docs": "", "Fields": [{ "Name": "IP", "Docs": "", "Typewords": ["string"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "Fail", "Docs": "", "Typewords": ["int32"] }, { "Name": "DKIMFail", "Docs": "", "Typewords": ["int32"] }, { "Name": "SPFFail", "Docs": "", "Typewords": ["int32"] }, { "Name": "Domains", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"AnalyticsReporter": { "Name": "AnalyticsReporter", "Docs": "", "Fields": [{ "Name": "OrgName", "Docs": "", "Typewords": ["string"] }, { "Name": "Reports", "Docs": "", "Typewords": ["int32"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "Fail", "Docs": "", "Typewords": ["int32"] }] },
+		"PostmasterData": { "Name": "PostmasterData", "Docs": "", "Fields": [{ "Name": "SNDS", "Docs": "", "Typewords": ["[]", "SNDSRecord"] }, { "Name": "Google", "Docs": "", "Typewords": ["[]", "GoogleStats"] }, { "Name": "Volumes", "Docs": "", "Typewords": ["[]", "Volume"] }] },
+		"SNDSRecord": { "Name": "SNDSRecord", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "IP", "Docs": "", "Typewords": ["string"] }, { "Name": "ActivityStart", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ActivityEnd", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "RcptCommands", "Docs": "", "Typewords": ["int32"] }, { "Name": "DataCommands", "Docs": "", "Typewords": ["int32"] }, { "Name": "MessageRecipients", "Docs": "", "Typewords": ["int32"] }, { "Name": "FilterResult", "Docs": "", "Typewords": ["string"] }, { "Name": "ComplaintRate", "Docs": "", "Typewords": ["string"] }, { "Name": "TrapPeriodStart", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "TrapPeriodEnd", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "TrapHits", "Docs": "", "Typewords": ["int32"] }, { "Name": "SampleHELO", "Docs": "", "Typewords": ["string"] }, { "Name": "SampleMailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "Comments", "Docs": "", "Typewords": ["string"] }, { "Name": "Updated", "Docs": "", "Typewords": ["timestamp"] }] },
+		"GoogleStats": { "Name": "GoogleStats", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "DayUTC", "Docs": "", "Typewords": ["string"] }, { "Name": "UserReportedSpamRatio", "Docs": "", "Typewords": ["float64"] }, { "Name": "DomainReputation", "Docs": "", "Typewords": ["string"] }, { "Name": "IPReputations", "Docs": "", "Typewords": ["[]", "GoogleIPReputation"] }, { "Name": "SPFSuccessRatio", "Docs": "", "Typewords": ["float64"] }, { "Name": "DKIMSuccessRatio", "Docs": "", "Typewords": ["float64"] }, { "Name": "DMARCSuccessRatio", "Docs": "", "Typewords": ["float64"] }, { "Name": "OutboundEncryptionRatio", "Docs": "", "Typewords": ["float64"] }, { "Name": "DeliveryErrors", "Docs": "", "Typewords": ["[]", "GoogleDeliveryError"] }, { "Name": "Updated", "Docs": "", "Typewords": ["timestamp"] }] },
+		"GoogleIPReputation": { "Name": "GoogleIPReputation", "Docs": "", "Fields": [{ "Name": "Reputation", "Docs": "", "Typewords": ["string"] }, { "Name": "IPCount", "Docs": "", "Typewords": ["int64"] }, { "Name": "SampleIPs", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"GoogleDeliveryError": { "Name": "GoogleDeliveryError", "Docs": "", "Fields": [{ "Name": "ErrorClass", "Docs": "", "Typewords": ["string"] }, { "Name": "ErrorType", "Docs": "", "Typewords": ["string"] }, { "Name": "ErrorRatio", "Docs": "", "Typewords": ["float64"] }] },
+		"Volume": { "Name": "Volume", "Docs": "", "Fields": [{ "Name": "DayUTC", "Docs": "", "Typewords": ["string"] }, { "Name": "Provider", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "Delivered", "Docs": "", "Typewords": ["int32"] }, { "Name": "Failed", "Docs": "", "Typewords": ["int32"] }] },
+		"Sender": { "Name": "Sender", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Type", "Docs": "", "Typewords": ["SenderType"] }, { "Name": "Value", "Docs": "", "Typewords": ["string"] }, { "Name": "First", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Last", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Accepted", "Docs": "", "Typewords": ["int64"] }, { "Name": "Rejected", "Docs": "", "Typewords": ["int64"] }, { "Name": "UnknownRecipients", "Docs": "", "Typewords": ["int64"] }, { "Name": "SPFPass", "Docs": "", "Typewords": ["int64"] }, { "Name": "DKIMPass", "Docs": "", "Typewords": ["int64"] }, { "Name": "DMARCPass", "Docs": "", "Typewords": ["int64"] }, { "Name": "DMARCFail", "Docs": "", "Typewords": ["int64"] }, { "Name": "Junk", "Docs": "", "Typewords": ["int64"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["int64"] }, { "Name": "Spamtraps", "Docs": "", "Typewords": ["int64"] }, { "Name": "Override", "Docs": "", "Typewords": ["Override"] }, { "Name": "OverrideUpdated", "Docs": "", "Typewords": ["timestamp"] }] },
 		"Reverse": { "Name": "Reverse", "Docs": "", "Fields": [{ "Name": "Hostnames", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Analysis": { "Name": "Analysis", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Record", "Docs": "", "Typewords": ["nullable", "AnalysisRecord"] }, { "Name": "DNSLookups", "Docs": "", "Typewords": ["int32"] }, { "Name": "VoidLookups", "Docs": "", "Typewords": ["int32"] }, { "Name": "Authentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"AnalysisRecord": { "Name": "AnalysisRecord", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "TXT", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "Mechanisms", "Docs": "", "Typewords": ["[]", "AnalysisMechanism"] }] },
+		"AnalysisMechanism": { "Name": "AnalysisMechanism", "Docs": "", "Fields": [{ "Name": "Mechanism", "Docs": "", "Typewords": ["string"] }, { "Name": "Expanded", "Docs": "", "Typewords": ["string"] }, { "Name": "DNSLookups", "Docs": "", "Typewords": ["int32"] }, { "Name": "Void", "Docs": "", "Typewords": ["bool"] }, { "Name": "IPs", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "Include", "Docs": "", "Typewords": ["nullable", "AnalysisRecord"] }] },
+		"DANEHost": { "Name": "DANEHost", "Docs": "", "Fields": [{ "Name": "Host", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Authentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keys", "Docs": "", "Typewords": ["[]", "DANEKey"] }, { "Name": "Published", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Records", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"DANEKey": { "Name": "DANEKey", "Docs": "", "Fields": [{ "Name": "KeyType", "Docs": "", "Typewords": ["string"] }, { "Name": "TLSA", "Docs": "", "Typewords": ["string"] }, { "Name": "Current", "Docs": "", "Typewords": ["bool"] }, { "Name": "Expires", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Next", "Docs": "", "Typewords": ["bool"] }, { "Name": "Configured", "Docs": "", "Typewords": ["bool"] }, { "Name": "Published", "Docs": "", "Typewords": ["bool"] }] },
+		"AccountImportResult": { "Name": "AccountImportResult", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "Password", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"SendSuspension": { "Name": "SendSuspension", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["uint8"] }, { "Name": "Suspended", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }] },
+		"MessageStorage": { "Name": "MessageStorage", "Docs": "", "Fields": [{ "Name": "Messages", "Docs": "", "Typewords": ["int32"] }, { "Name": "Compressed", "Docs": "", "Typewords": ["int32"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "StoredSize", "Docs": "", "Typewords": ["int64"] }] },
+		"JunkFilterStats": { "Name": "JunkFilterStats", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["JunkStats"] }, { "Name": "Shared", "Docs": "", "Typewords": ["nullable", "JunkStats"] }] },
+		"JunkStats": { "Name": "JunkStats", "Docs": "", "Fields": [{ "Name": "Hams", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Spams", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Words", "Docs": "", "Typewords": ["int32"] }] },
+		"JunkVerdict": { "Name": "JunkVerdict", "Docs": "", "Fields": [{ "Name": "MessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Verdict", "Docs": "", "Typewords": ["MessageJunkVerdict"] }] },
+		"MessageJunkVerdict": { "Name": "MessageJunkVerdict", "Docs": "", "Fields": [{ "Name": "Accept", "Docs": "", "Typewords": ["bool"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }, { "Name": "Details", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Classified", "Docs": "", "Typewords": ["bool"] }, { "Name": "Score", "Docs": "", "Typewords": ["float64"] }, { "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "Significant", "Docs": "", "Typewords": ["bool"] }, { "Name": "HamWords", "Docs": "", "Typewords": ["[]", "MessageJunkWord"] }, { "Name": "SpamWords", "Docs": "", "Typewords": ["[]", "MessageJunkWord"] }] },
+		"MessageJunkWord": { "Name": "MessageJunkWord", "Docs": "", "Fields": [{ "Name": "Word", "Docs": "", "Typewords": ["string"] }, { "Name": "Score", "Docs": "", "Typewords": ["float64"] }] },
+		"Passkey": { "Name": "Passkey", "Docs": "", "Fields": [{ "Name": "CredentialID", "Docs": "", "Typewords": ["string"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "SignCount", "Docs": "", "Typewords": ["uint32"] }, { "Name": "LastUsed", "Docs": "", "Typewords": ["timestamp"] }] },
+		"TOTPSetup": { "Name": "TOTPSetup", "Docs": "", "Fields": [{ "Name": "Secret", "Docs": "", "Typewords": ["string"] }, { "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "QRCodePNG", "Docs": "", "Typewords": ["string"] }] },
+		"PasskeyCreateOptions": { "Name": "PasskeyCreateOptions", "Docs": "", "Fields": [{ "Name": "Challenge", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "RPID", "Docs": "", "Typewords": ["string"] }, { "Name": "RPName", "Docs": "", "Typewords": ["string"] }, { "Name": "UserID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "UserName", "Docs": "", "Typewords": ["string"] }, { "Name": "Algorithms", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "ExcludeCredentials", "Docs": "", "Typewords": ["[]", "nullable", "string"] }, { "Name": "Timeout", "Docs": "", "Typewords": ["int32"] }] },
+		"AdminUser": { "Name": "AdminUser", "Docs": "", "Fields": [{ "Name": "Role", "Docs": "", "Typewords": ["string"] }, { "Name": "Domains", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"SignupRequest": { "Name": "SignupRequest", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "State", "Docs": "", "Typewords": ["SignupState"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "FullName", "Docs": "", "Typewords": ["string"] }, { "Name": "ContactEmail", "Docs": "", "Typewords": ["string"] }, { "Name": "InviteID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "Verified", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Decided", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "DecidedBy", "Docs": "", "Typewords": ["string"] }, { "Name": "RejectReason", "Docs": "", "Typewords": ["string"] }] },
+		"SignupInvite": { "Name": "SignupInvite", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Expires", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "MaxUses", "Docs": "", "Typewords": ["int32"] }, { "Name": "Uses", "Docs": "", "Typewords": ["int32"] }, { "Name": "Note", "Docs": "", "Typewords": ["string"] }, { "Name": "CreatedBy", "Docs": "", "Typewords": ["string"] }] },
 		"ClientConfigs": { "Name": "ClientConfigs", "Docs": "", "Fields": [{ "Name": "Entries", "Docs": "", "Typewords": ["[]", "ClientConfigsEntry"] }] },
 		"ClientConfigsEntry": { "Name": "ClientConfigsEntry", "Docs": "", "Fields": [{ "Name": "Protocol", "Docs": "", "Typewords": ["string"] }, { "Name": "Host", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Port", "Docs": "", "Typewords": ["int32"] }, { "Name": "Listener", "Docs": "", "Typewords": ["string"] }, { "Name": "Note", "Docs": "", "Typewords": ["string"] }] },
 		"HoldRule": { "Name": "HoldRule", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "SenderDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientDomainStr", "Docs": "", "Typewords": ["string"] }] },
-		"Filter": { "Name": "Filter", "Docs": "", "Fields": [{ "Name": "Max", "Docs": "", "Typewords": ["int32"] }, { "Name": "IDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["string"] }, { "Name": "Hold", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "Submitted", "Docs": "", "Typewords": ["string"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"Filter": { "Name": "Filter", "Docs": "", "Fields": [{ "Name": "Max", "Docs": "", "Typewords": ["int32"] }, { "Name": "IDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["string"] }, { "Name": "Hold", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "Submitted", "Docs": "", "Typewords": ["string"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "LastError", "Docs": "", "Typewords": ["string"] }] },
 		"Sort": { "Name": "Sort", "Docs": "", "Fields": [{ "Name": "Field", "Docs": "", "Typewords": ["string"] }, { "Name": "LastID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Last", "Docs": "", "Typewords": ["any"] }, { "Name": "Asc", "Docs": "", "Typewords": ["bool"] }] },
-		"Msg": { "Name": "Msg", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "BaseID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Queued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Hold", "Docs": "", "Typewords": ["bool"] }, { "Name": "SenderAccount", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "SenderDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "SenderDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "RecipientDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "DialedIPs", "Docs": "", "Typewords": ["{}", "[]", "IP"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "MsgResult"] }, { "Name": "Has8bit", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMTPUTF8", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsDMARCReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsTLSReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "DSNUTF8", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureReleaseRequest", "Docs": "", "Typewords": ["string"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }] },
+		"Msg": { "Name": "Msg", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "BaseID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Queued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Hold", "Docs": "", "Typewords": ["bool"] }, { "Name": "HoldManual", "Docs": "", "Typewords": ["bool"] }, { "Name": "SenderAccount", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "SenderDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "SenderDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "RecipientDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "DialedIPs", "Docs": "", "Typewords": ["{}", "[]", "IP"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "MsgResult"] }, { "Name": "Has8bit", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMTPUTF8", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsDMARCReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsTLSReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsListMessage", "Docs": "", "Typewords": ["bool"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "DSNUTF8", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureReleaseRequest", "Docs": "", "Typewords": ["string"] }, { "Name": "Priority", "Docs": "", "Typewords": ["int32"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "Changes", "Docs": "", "Typewords": ["[]", "MsgChange"] }] },
 		"IPDomain": { "Name": "IPDomain", "Docs": "", "Fields": [{ "Name": "IP", "Docs": "", "Typewords": ["IP"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }] },
-		"MsgResult": { "Name": "MsgResult", "Docs": "", "Fields": [{ "Name": "Start", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Duration", "Docs": "", "Typewords": ["int64"] }, { "Name": "Success", "Docs": "", "Typewords": ["bool"] }, { "Name": "Code", "Docs": "", "Typewords": ["int32"] }, { "Name": "Secode", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"MsgResult": { "Name": "MsgResult", "Docs": "", "Fields": [{ "Name": "Start", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Duration", "Docs": "", "Typewords": ["int64"] }, { "Name": "Success", "Docs": "", "Typewords": ["bool"] }, { "Name": "Code", "Docs": "", "Typewords": ["int32"] }, { "Name": "Secode", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteMTA", "Docs": "", "Typewords": ["string"] }, { "Name": "Response", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "TLS", "Docs": "", "Typewords": ["string"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["bool"] }, { "Name": "DANE", "Docs": "", "Typewords": ["bool"] }, { "Name": "Transcript", "Docs": "", "Typewords": ["string"] }, { "Name": "DNSLookups", "Docs": "", "Typewords": ["[]", "Lookup"] }, { "Name": "DANEStatus", "Docs": "", "Typewords": ["string"] }] },
+		"Lookup": { "Name": "Lookup", "Docs": "", "Fields": [{ "Name": "Type", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Authentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"MsgChange": { "Name": "MsgChange", "Docs": "", "Fields": [{ "Name": "Time", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }] },
+		"MsgRewrite": { "Name": "MsgRewrite", "Docs": "", "Fields": [{ "Name": "Recipient", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["nullable", "string"] }] },
 		"RetiredFilter": { "Name": "RetiredFilter", "Docs": "", "Fields": [{ "Name": "Max", "Docs": "", "Typewords": ["int32"] }, { "Name": "IDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["string"] }, { "Name": "Submitted", "Docs": "", "Typewords": ["string"] }, { "Name": "LastActivity", "Docs": "", "Typewords": ["string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Success", "Docs": "", "Typewords": ["nullable", "bool"] }] },
 		"RetiredSort": { "Name": "RetiredSort", "Docs": "", "Fields": [{ "Name": "Field", "Docs": "", "Typewords": ["string"] }, { "Name": "LastID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Last", "Docs": "", "Typewords": ["any"] }, { "Name": "Asc", "Docs": "", "Typewords": ["bool"] }] },
-		"MsgRetired": { "Name": "MsgRetired", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "BaseID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Queued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SenderAccount", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "SenderDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "RecipientDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "DialedIPs", "Docs": "", "Typewords": ["{}", "[]", "IP"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "MsgResult"] }, { "Name": "Has8bit", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMTPUTF8", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsDMARCReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsTLSReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureReleaseRequest", "Docs": "", "Typewords": ["string"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "LastActivity", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "RecipientAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Success", "Docs": "", "Typewords": ["bool"] }, { "Name": "KeepUntil", "Docs": "", "Typewords": ["timestamp"] }] },
+		"MsgRetired": { "Name": "MsgRetired", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "BaseID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Queued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SenderAccount", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "SenderDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "RecipientDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "DialedIPs", "Docs": "", "Typewords": ["{}", "[]", "IP"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "MsgResult"] }, { "Name": "Has8bit", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMTPUTF8", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsDMARCReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsTLSReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsListMessage", "Docs": "", "Typewords": ["bool"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureReleaseRequest", "Docs": "", "Typewords": ["string"] }, { "Name": "Priority", "Docs": "", "Typewords": ["int32"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "Changes", "Docs": "", "Typewords": ["[]", "MsgChange"] }, { "Name": "LastActivity", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "RecipientAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Success", "Docs": "", "Typewords": ["bool"] }, { "Name": "KeepUntil", "Docs": "", "Typewords": ["timestamp"] }] },
+		"DeadFilter": { "Name": "DeadFilter", "Docs": "", "Fields": [{ "Name": "Max", "Docs": "", "Typewords": ["int32"] }, { "Name": "IDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["string"] }] },
+		"MsgDead": { "Name": "MsgDead", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Queued", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SenderAccount", "Docs": "", "Typewords": ["string"] }, { "Name": "SenderLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "SenderDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "SenderDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["IPDomain"] }, { "Name": "RecipientDomainStr", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "DialedIPs", "Docs": "", "Typewords": ["{}", "[]", "IP"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "MsgResult"] }, { "Name": "Has8bit", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMTPUTF8", "Docs": "", "Typewords": ["bool"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Transport", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureReleaseRequest", "Docs": "", "Typewords": ["string"] }, { "Name": "Priority", "Docs": "", "Typewords": ["int32"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "Failed", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "KeepUntil", "Docs": "", "Typewords": ["timestamp"] }] },
+		"HostHealth": { "Name": "HostHealth", "Docs": "", "Fields": [{ "Name": "Host", "Docs": "", "Typewords": ["string"] }, { "Name": "LastAttempt", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastSuccess", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastFailure", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "LastError", "Docs": "", "Typewords": ["string"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int64"] }, { "Name": "Successes", "Docs": "", "Typewords": ["int64"] }, { "Name": "ConnectFailures", "Docs": "", "Typewords": ["int64"] }, { "Name": "TLSFailures", "Docs": "", "Typewords": ["int64"] }, { "Name": "TemporaryFailures", "Docs": "", "Typewords": ["int64"] }, { "Name": "ConsecutiveFailures", "Docs": "", "Typewords": ["int32"] }, { "Name": "CooldownUntil", "Docs": "", "Typewords": ["timestamp"] }] },
 		"HookFilter": { "Name": "HookFilter", "Docs": "", "Fields": [{ "Name": "Max", "Docs": "", "Typewords": ["int32"] }, { "Name": "IDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Submitted", "Docs": "", "Typewords": ["string"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["string"] }, { "Name": "Event", "Docs": "", "Typewords": ["string"] }] },
 		"HookSort": { "Name": "HookSort", "Docs": "", "Fields": [{ "Name": "Field", "Docs": "", "Typewords": ["string"] }, { "Name": "LastID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Last", "Docs": "", "Typewords": ["any"] }, { "Name": "Asc", "Docs": "", "Typewords": ["bool"] }] },
 		"Hook": { "Name": "Hook", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "QueueMsgID", "Docs": "", "Typewords": ["int64"] }, { "Name": "FromID", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Extra", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Authorization", "Docs": "", "Typewords": ["string"] }, { "Name": "IsIncoming", "Docs": "", "Typewords": ["bool"] }, { "Name": "OutgoingEvent", "Docs": "", "Typewords": ["string"] }, { "Name": "Payload", "Docs": "", "Typewords": ["string"] }, { "Name": "Submitted", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Attempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "NextAttempt", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "HookResult"] }] },
@@ -369,25 +448,46 @@ var api;
 		"TransportSMTP": { "Name": "TransportSMTP", "Docs": "", "Fields": [{ "Name": "Host", "Docs": "", "Typewords": ["string"] }, { "Name": "Port", "Docs": "", "Typewords": ["int32"] }, { "Name": "STARTTLSInsecureSkipVerify", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoSTARTTLS", "Docs": "", "Typewords": ["bool"] }, { "Name": "Auth", "Docs": "", "Typewords": ["nullable", "SMTPAuth"] }] },
 		"SMTPAuth": { "Name": "SMTPAuth", "Docs": "", "Fields": [{ "Name": "Username", "Docs": "", "Typewords": ["string"] }, { "Name": "Password", "Docs": "", "Typewords": ["string"] }, { "Name": "Mechanisms", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"TransportSocks": { "Name": "TransportSocks", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPs", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "RemoteHostname", "Docs": "", "Typewords": ["string"] }] },
-		"TransportDirect": { "Name": "TransportDirect", "Docs": "", "Fields": [{ "Name": "DisableIPv4", "Docs": "", "Typewords": ["bool"] }, { "Name": "DisableIPv6", "Docs": "", "Typewords": ["bool"] }] },
+		"TransportDirect": { "Name": "TransportDirect", "Docs": "", "Fields": [{ "Name": "DisableIPv4", "Docs": "", "Typewords": ["bool"] }, { "Name": "DisableIPv6", "Docs": "", "Typewords": ["bool"] }, { "Name": "PreferIPFamily", "Docs": "", "Typewords": ["string"] }, { "Name": "HappyEyeballsDelay", "Docs": "", "Typewords": ["int64"] }, { "Name": "DisableHappyEyeballs", "Docs": "", "Typewords": ["bool"] }] },
 		"EvaluationStat": { "Name": "EvaluationStat", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "Dispositions", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Count", "Docs": "", "Typewords": ["int32"] }, { "Name": "SendReport", "Docs": "", "Typewords": ["bool"] }] },
 		"Evaluation": { "Name": "Evaluation", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "PolicyDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "Evaluated", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Optional", "Docs": "", "Typewords": ["bool"] }, { "Name": "IntervalHours", "Docs": "", "Typewords": ["int32"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "PolicyPublished", "Docs": "", "Typewords": ["PolicyPublished"] }, { "Name": "SourceIP", "Docs": "", "Typewords": ["string"] }, { "Name": "Disposition", "Docs": "", "Typewords": ["string"] }, { "Name": "AlignedDKIMPass", "Docs": "", "Typewords": ["bool"] }, { "Name": "AlignedSPFPass", "Docs": "", "Typewords": ["bool"] }, { "Name": "OverrideReasons", "Docs": "", "Typewords": ["[]", "PolicyOverrideReason"] }, { "Name": "EnvelopeTo", "Docs": "", "Typewords": ["string"] }, { "Name": "EnvelopeFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "HeaderFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "DKIMResults", "Docs": "", "Typewords": ["[]", "DKIMAuthResult"] }, { "Name": "SPFResults", "Docs": "", "Typewords": ["[]", "SPFAuthResult"] }] },
 		"SuppressAddress": { "Name": "SuppressAddress", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Inserted", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ReportingAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Until", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }] },
 		"TLSResult": { "Name": "TLSResult", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "PolicyDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "DayUTC", "Docs": "", "Typewords": ["string"] }, { "Name": "RecipientDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Updated", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "IsHost", "Docs": "", "Typewords": ["bool"] }, { "Name": "SendReport", "Docs": "", "Typewords": ["bool"] }, { "Name": "SentToRecipientDomain", "Docs": "", "Typewords": ["bool"] }, { "Name": "RecipientDomainReportingAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SentToPolicyDomain", "Docs": "", "Typewords": ["bool"] }, { "Name": "Results", "Docs": "", "Typewords": ["[]", "Result"] }] },
 		"TLSRPTSuppressAddress": { "Name": "TLSRPTSuppressAddress", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Inserted", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ReportingAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "Until", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }] },
-		"Dynamic": { "Name": "Dynamic", "Docs": "", "Fields": [{ "Name": "Domains", "Docs": "", "Typewords": ["{}", "ConfigDomain"] }, { "Name": "Accounts", "Docs": "", "Typewords": ["{}", "Account"] }, { "Name": "WebDomainRedirects", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "WebHandlers", "Docs": "", "Typewords": ["[]", "WebHandler"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "MonitorDNSBLs", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MonitorDNSBLZones", "Docs": "", "Typewords": ["[]", "Domain"] }] },
+		"Dynamic": { "Name": "Dynamic", "Docs": "", "Fields": [{ "Name": "Domains", "Docs": "", "Typewords": ["{}", "ConfigDomain"] }, { "Name": "Accounts", "Docs": "", "Typewords": ["{}", "Account"] }, { "Name": "WebDomainRedirects", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "WebHandlers", "Docs": "", "Typewords": ["[]", "WebHandler"] }, { "Name": "Routes", "Docs": "", "Typewords": ["[]", "Route"] }, { "Name": "RetrySchedules", "Docs": "", "Typewords": ["[]", "RetrySchedule"] }, { "Name": "TLSPolicies", "Docs": "", "Typewords": ["[]", "TLSPolicy"] }, { "Name": "MonitorDNSBLs", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MonitorDNSBLZones", "Docs": "", "Typewords": ["[]", "Domain"] }] },
+		"RetrySchedule": { "Name": "RetrySchedule", "Docs": "", "Fields": [{ "Name": "ToDomain", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Class", "Docs": "", "Typewords": ["string"] }, { "Name": "MinPriority", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxPriority", "Docs": "", "Typewords": ["int32"] }, { "Name": "Intervals", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "MaxAttempts", "Docs": "", "Typewords": ["int32"] }, { "Name": "MaxAge", "Docs": "", "Typewords": ["int64"] }, { "Name": "ToDomainASCII", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"TLSPolicy": { "Name": "TLSPolicy", "Docs": "", "Fields": [{ "Name": "ToDomain", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Mode", "Docs": "", "Typewords": ["string"] }, { "Name": "CertificateSHA256", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ToDomainASCII", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"DynamicConfig": { "Name": "DynamicConfig", "Docs": "", "Fields": [{ "Name": "Config", "Docs": "", "Typewords": ["Dynamic"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }, { "Name": "Hash", "Docs": "", "Typewords": ["string"] }] },
+		"DKIMRotationStatus": { "Name": "DKIMRotationStatus", "Docs": "", "Fields": [{ "Name": "Config", "Docs": "", "Typewords": ["nullable", "DKIMRotation"] }, { "Name": "Provisioned", "Docs": "", "Typewords": ["bool"] }, { "Name": "Rotated", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Added", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Switched", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Old", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "New", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "NextStage", "Docs": "", "Typewords": ["string"] }, { "Name": "Next", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Tasks", "Docs": "", "Typewords": ["[]", "DKIMRotationTask"] }, { "Name": "History", "Docs": "", "Typewords": ["[]", "DKIMRotationEvent"] }] },
+		"DKIMRotationTask": { "Name": "DKIMRotationTask", "Docs": "", "Fields": [{ "Name": "Action", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Value", "Docs": "", "Typewords": ["string"] }] },
+		"DKIMRotationEvent": { "Name": "DKIMRotationEvent", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Time", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Kind", "Docs": "", "Typewords": ["string"] }, { "Name": "Selectors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }] },
+		"Subscriber": { "Name": "Subscriber", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "List", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "Token", "Docs": "", "Typewords": ["string"] }, { "Name": "Confirmed", "Docs": "", "Typewords": ["bool"] }, { "Name": "Digest", "Docs": "", "Typewords": ["bool"] }, { "Name": "Bounces", "Docs": "", "Typewords": ["int32"] }, { "Name": "LastBounce", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }] },
+		"Message": { "Name": "Message", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "List", "Docs": "", "Typewords": ["string"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "DigestPending", "Docs": "", "Typewords": ["bool"] }] },
 		"TLSPublicKey": { "Name": "TLSPublicKey", "Docs": "", "Fields": [{ "Name": "Fingerprint", "Docs": "", "Typewords": ["string"] }, { "Name": "Created", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Type", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "NoIMAPPreauth", "Docs": "", "Typewords": ["bool"] }, { "Name": "CertDER", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }] },
 		"LoginAttempt": { "Name": "LoginAttempt", "Docs": "", "Fields": [{ "Name": "Key", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Last", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "First", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Count", "Docs": "", "Typewords": ["int64"] }, { "Name": "AccountName", "Docs": "", "Typewords": ["string"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "LocalIP", "Docs": "", "Typewords": ["string"] }, { "Name": "TLS", "Docs": "", "Typewords": ["string"] }, { "Name": "TLSPubKeyFingerprint", "Docs": "", "Typewords": ["string"] }, { "Name": "Protocol", "Docs": "", "Typewords": ["string"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "AuthMech", "Docs": "", "Typewords": ["string"] }, { "Name": "Result", "Docs": "", "Typewords": ["AuthResult"] }] },
+		"AuditFilter": { "Name": "AuditFilter", "Docs": "", "Fields": [{ "Name": "Kind", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Actor", "Docs": "", "Typewords": ["string"] }, { "Name": "Start", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "End", "Docs": "", "Typewords": ["nullable", "timestamp"] }] },
+		"AuditEvent": { "Name": "AuditEvent", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Time", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Kind", "Docs": "", "Typewords": ["string"] }, { "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Actor", "Docs": "", "Typewords": ["string"] }, { "Name": "Protocol", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "Action", "Docs": "", "Typewords": ["string"] }, { "Name": "Result", "Docs": "", "Typewords": ["string"] }, { "Name": "Details", "Docs": "", "Typewords": ["string"] }] },
+		"QuotaStatus": { "Name": "QuotaStatus", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "Quota", "Docs": "", "Typewords": ["int64"] }, { "Name": "SoftLimit", "Docs": "", "Typewords": ["int64"] }, { "Name": "Percent", "Docs": "", "Typewords": ["int32"] }, { "Name": "GraceEnd", "Docs": "", "Typewords": ["timestamp"] }] },
+		"MTASTSRollout": { "Name": "MTASTSRollout", "Docs": "", "Fields": [{ "Name": "Policy", "Docs": "", "Typewords": ["nullable", "MTASTS"] }, { "Name": "NextMode", "Docs": "", "Typewords": ["string"] }, { "Name": "Ready", "Docs": "", "Typewords": ["bool"] }, { "Name": "Reasons", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "TestingDays", "Docs": "", "Typewords": ["int32"] }, { "Name": "TestingSuccess", "Docs": "", "Typewords": ["int64"] }, { "Name": "TestingFailure", "Docs": "", "Typewords": ["int64"] }, { "Name": "RecentFailure", "Docs": "", "Typewords": ["int64"] }, { "Name": "FailureTypes", "Docs": "", "Typewords": ["{}", "int64"] }, { "Name": "LastReportPeriod", "Docs": "", "Typewords": ["timestamp"] }] },
+		"DomainReadiness": { "Name": "DomainReadiness", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Checked", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Checking", "Docs": "", "Typewords": ["bool"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "Ready", "Docs": "", "Typewords": ["bool"] }, { "Name": "Done", "Docs": "", "Typewords": ["int32"] }, { "Name": "Items", "Docs": "", "Typewords": ["[]", "ReadinessItem"] }, { "Name": "Records", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"ReadinessItem": { "Name": "ReadinessItem", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Status", "Docs": "", "Typewords": ["string"] }, { "Name": "Errors", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Warnings", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Instructions", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"CSRFToken": { "Name": "CSRFToken", "Docs": "", "Values": null },
 		"DMARCPolicy": { "Name": "DMARCPolicy", "Docs": "", "Values": [{ "Name": "PolicyEmpty", "Value": "", "Docs": "" }, { "Name": "PolicyNone", "Value": "none", "Docs": "" }, { "Name": "PolicyQuarantine", "Value": "quarantine", "Docs": "" }, { "Name": "PolicyReject", "Value": "reject", "Docs": "" }] },
 		"Align": { "Name": "Align", "Docs": "", "Values": [{ "Name": "AlignStrict", "Value": "s", "Docs": "" }, { "Name": "AlignRelaxed", "Value": "r", "Docs": "" }] },
 		"RUA": { "Name": "RUA", "Docs": "", "Values": null },
 		"Mode": { "Name": "Mode", "Docs": "", "Values": [{ "Name": "ModeEnforce", "Value": "enforce", "Docs": "" }, { "Name": "ModeTesting", "Value": "testing", "Docs": "" }, { "Name": "ModeNone", "Value": "none", "Docs": "" }] },
 		"Localpart": { "Name": "Localpart", "Docs": "", "Values": null },
+		"FailureCategory": { "Name": "FailureCategory", "Docs": "", "Values": [{ "Name": "CategorySTARTTLS", "Value": "starttls", "Docs": "" }, { "Name": "CategoryCertificate", "Value": "certificate", "Docs": "" }, { "Name": "CategoryDANE", "Value": "dane", "Docs": "" }, { "Name": "CategoryMTASTS", "Value": "mtasts", "Docs": "" }, { "Name": "CategoryOther", "Value": "other", "Docs": "" }] },
+		"SenderType": { "Name": "SenderType", "Docs": "", "Values": [{ "Name": "SenderDomain", "Value": "domain", "Docs": "" }, { "Name": "SenderIP", "Value": "ip", "Docs": "" }] },
+		"Override": { "Name": "Override", "Docs": "", "Values": [{ "Name": "OverrideNone", "Value": "", "Docs": "" }, { "Name": "OverrideAllow", "Value": "allow", "Docs": "" }, { "Name": "OverrideQuarantine", "Value": "quarantine", "Docs": "" }] },
+		"Status": { "Name": "Status", "Docs": "", "Values": [{ "Name": "StatusNone", "Value": "none", "Docs": "" }, { "Name": "StatusNeutral", "Value": "neutral", "Docs": "" }, { "Name": "StatusPass", "Value": "pass", "Docs": "" }, { "Name": "StatusFail", "Value": "fail", "Docs": "" }, { "Name": "StatusSoftfail", "Value": "softfail", "Docs": "" }, { "Name": "StatusTemperror", "Value": "temperror", "Docs": "" }, { "Name": "StatusPermerror", "Value": "permerror", "Docs": "" }] },
+		"SignupState": { "Name": "SignupState", "Docs": "", "Values": [{ "Name": "SignupUnverified", "Value": "unverified", "Docs": "" }, { "Name": "SignupPending", "Value": "pending", "Docs": "" }, { "Name": "SignupApproved", "Value": "approved", "Docs": "" }, { "Name": "SignupRejected", "Value": "rejected", "Docs": "" }] },
 		"IP": { "Name": "IP", "Docs": "", "Values": [] },
-		"AuthResult": { "Name": "AuthResult", "Docs": "", "Values": [{ "Name": "AuthSuccess", "Value": "ok", "Docs": "" }, { "Name": "AuthBadUser", "Value": "baduser", "Docs": "" }, { "Name": "AuthBadPassword", "Value": "badpassword", "Docs": "" }, { "Name": "AuthBadCredentials", "Value": "badcreds", "Docs": "" }, { "Name": "AuthBadChannelBinding", "Value": "badchanbind", "Docs": "" }, { "Name": "AuthBadProtocol", "Value": "badprotocol", "Docs": "" }, { "Name": "AuthLoginDisabled", "Value": "logindisabled", "Docs": "" }, { "Name": "AuthError", "Value": "error", "Docs": "" }, { "Name": "AuthAborted", "Value": "aborted", "Docs": "" }] },
+		"AuthResult": { "Name": "AuthResult", "Docs": "", "Values": [{ "Name": "AuthSuccess", "Value": "ok", "Docs": "" }, { "Name": "AuthBadUser", "Value": "baduser", "Docs": "" }, { "Name": "AuthBadPassword", "Value": "badpassword", "Docs": "" }, { "Name": "AuthBadCredentials", "Value": "badcreds", "Docs": "" }, { "Name": "AuthBadChannelBinding", "Value": "badchanbind", "Docs": "" }, { "Name": "AuthBadProtocol", "Value": "badprotocol", "Docs": "" }, { "Name": "AuthLoginDisabled", "Value": "logindisabled", "Docs": "" }, { "Name": "AuthLoginLocked", "Value": "loginlocked", "Docs": "" }, { "Name": "AuthPasswordExpired", "Value": "passwordexpired", "Docs": "" }, { "Name": "AuthAppPasswordRequired", "Value": "apppasswordrequired", "Docs": "" }, { "Name": "AuthTOTPRequired", "Value": "totprequired", "Docs": "" }, { "Name": "AuthTwoFactorSetup", "Value": "twofactorsetup", "Docs": "" }, { "Name": "AuthError", "Value": "error", "Docs": "" }, { "Name": "AuthAborted", "Value": "aborted", "Docs": "" }] },
 	};
 	api.parser = {
+		PasskeyGetOptions: (v) => api.parse("PasskeyGetOptions", v),
+		PasskeyAssertion: (v) => api.parse("PasskeyAssertion", v),
 		CheckResult: (v) => api.parse("CheckResult", v),
 		DNSSECResult: (v) => api.parse("DNSSECResult", v),
 		IPRevCheckResult: (v) => api.parse("IPRevCheckResult", v),
@@ -423,21 +523,42 @@ var api;
 		DKIM: (v) => api.parse("DKIM", v),
 		Selector: (v) => api.parse("Selector", v),
 		Canonicalization: (v) => api.parse("Canonicalization", v),
+		DKIMSignRule: (v) => api.parse("DKIMSignRule", v),
+		DKIMRotation: (v) => api.parse("DKIMRotation", v),
 		DMARC: (v) => api.parse("DMARC", v),
 		MTASTS: (v) => api.parse("MTASTS", v),
 		TLSRPT: (v) => api.parse("TLSRPT", v),
 		Route: (v) => api.parse("Route", v),
 		Alias: (v) => api.parse("Alias", v),
+		AliasMember: (v) => api.parse("AliasMember", v),
+		AliasList: (v) => api.parse("AliasList", v),
 		AliasAddress: (v) => api.parse("AliasAddress", v),
 		Address: (v) => api.parse("Address", v),
 		Destination: (v) => api.parse("Destination", v),
 		Ruleset: (v) => api.parse("Ruleset", v),
+		OutgoingHeaderRules: (v) => api.parse("OutgoingHeaderRules", v),
+		OutgoingFooter: (v) => api.parse("OutgoingFooter", v),
+		MessageTemplate: (v) => api.parse("MessageTemplate", v),
+		DNSProvisioning: (v) => api.parse("DNSProvisioning", v),
+		DomainSignup: (v) => api.parse("DomainSignup", v),
+		DomainWeb: (v) => api.parse("DomainWeb", v),
+		WebmailDefaults: (v) => api.parse("WebmailDefaults", v),
+		BIMI: (v) => api.parse("BIMI", v),
+		SpamActions: (v) => api.parse("SpamActions", v),
+		SharedJunkFilter: (v) => api.parse("SharedJunkFilter", v),
 		Account: (v) => api.parse("Account", v),
 		OutgoingWebhook: (v) => api.parse("OutgoingWebhook", v),
 		IncomingWebhook: (v) => api.parse("IncomingWebhook", v),
 		SubjectPass: (v) => api.parse("SubjectPass", v),
 		AutomaticJunkFlags: (v) => api.parse("AutomaticJunkFlags", v),
 		JunkFilter: (v) => api.parse("JunkFilter", v),
+		JunkReevaluation: (v) => api.parse("JunkReevaluation", v),
+		Screening: (v) => api.parse("Screening", v),
+		Categories: (v) => api.parse("Categories", v),
+		Category: (v) => api.parse("Category", v),
+		PasswordPolicy: (v) => api.parse("PasswordPolicy", v),
+		Identity: (v) => api.parse("Identity", v),
+		Delegation: (v) => api.parse("Delegation", v),
 		AddressAlias: (v) => api.parse("AddressAlias", v),
 		PolicyRecord: (v) => api.parse("PolicyRecord", v),
 		TLSReportRecord: (v) => api.parse("TLSReportRecord", v),
@@ -448,6 +569,12 @@ var api;
 		Summary: (v) => api.parse("Summary", v),
 		FailureDetails: (v) => api.parse("FailureDetails", v),
 		TLSRPTSummary: (v) => api.parse("TLSRPTSummary", v),
+		Stats: (v) => api.parse("Stats", v),
+		StatsCategory: (v) => api.parse("StatsCategory", v),
+		StatsResultType: (v) => api.parse("StatsResultType", v),
+		StatsDay: (v) => api.parse("StatsDay", v),
+		StatsReporter: (v) => api.parse("StatsReporter", v),
+		FailureType: (v) => api.parse("FailureType", v),
 		DomainFeedback: (v) => api.parse("DomainFeedback", v),
 		ReportMetadata: (v) => api.parse("ReportMetadata", v),
 		DateRange: (v) => api.parse("DateRange", v),
@@ -460,8 +587,39 @@ var api;
 		AuthResults: (v) => api.parse("AuthResults", v),
 		DKIMAuthResult: (v) => api.parse("DKIMAuthResult", v),
 		SPFAuthResult: (v) => api.parse("SPFAuthResult", v),
+		DomainFailureReport: (v) => api.parse("DomainFailureReport", v),
 		DMARCSummary: (v) => api.parse("DMARCSummary", v),
+		Analytics: (v) => api.parse("Analytics", v),
+		AnalyticsDay: (v) => api.parse("AnalyticsDay", v),
+		AnalyticsSource: (v) => api.parse("AnalyticsSource", v),
+		AnalyticsReporter: (v) => api.parse("AnalyticsReporter", v),
+		PostmasterData: (v) => api.parse("PostmasterData", v),
+		SNDSRecord: (v) => api.parse("SNDSRecord", v),
+		GoogleStats: (v) => api.parse("GoogleStats", v),
+		GoogleIPReputation: (v) => api.parse("GoogleIPReputation", v),
+		GoogleDeliveryError: (v) => api.parse("GoogleDeliveryError", v),
+		Volume: (v) => api.parse("Volume", v),
+		Sender: (v) => api.parse("Sender", v),
 		Reverse: (v) => api.parse("Reverse", v),
+		Analysis: (v) => api.parse("Analysis", v),
+		AnalysisRecord: (v) => api.parse("AnalysisRecord", v),
+		AnalysisMechanism: (v) => api.parse("AnalysisMechanism", v),
+		DANEHost: (v) => api.parse("DANEHost", v),
+		DANEKey: (v) => api.parse("DANEKey", v),
+		AccountImportResult: (v) => api.parse("AccountImportResult", v),
+		SendSuspension: (v) => api.parse("SendSuspension", v),
+		MessageStorage: (v) => api.parse("MessageStorage", v),
+		JunkFilterStats: (v) => api.parse("JunkFilterStats", v),
+		JunkStats: (v) => api.parse("JunkStats", v),
+		JunkVerdict: (v) => api.parse("JunkVerdict", v),
+		MessageJunkVerdict: (v) => api.parse("MessageJunkVerdict", v),
+		MessageJunkWord: (v) => api.parse("MessageJunkWord", v),
+		Passkey: (v) => api.parse("Passkey", v),
+		TOTPSetup: (v) => api.parse("TOTPSetup", v),
+		PasskeyCreateOptions: (v) => api.parse("PasskeyCreateOptions", v),
+		AdminUser: (v) => api.parse("AdminUser", v),
+		SignupRequest: (v) => api.parse("SignupRequest", v),
+		SignupInvite: (v) => api.parse("SignupInvite", v),
 		ClientConfigs: (v) => api.parse("ClientConfigs", v),
 		ClientConfigsEntry: (v) => api.parse("ClientConfigsEntry", v),
 		HoldRule: (v) => api.parse("HoldRule", v),
@@ -470,9 +628,15 @@ var api;
 		Msg: (v) => api.parse("Msg", v),
 		IPDomain: (v) => api.parse("IPDomain", v),
 		MsgResult: (v) => api.parse("MsgResult", v),
+		Lookup: (v) => api.parse("Lookup", v),
+		MsgChange: (v) => api.parse("MsgChange", v),
+		MsgRewrite: (v) => api.parse("MsgRewrite", v),
 		RetiredFilter: (v) => api.parse("RetiredFilter", v),
 		RetiredSort: (v) => api.parse("RetiredSort", v),
 		MsgRetired: (v) => api.parse("MsgRetired", v),
+		DeadFilter: (v) => api.parse("DeadFilter", v),
+		MsgDead: (v) => api.parse("MsgDead", v),
+		HostHealth: (v) => api.parse("HostHealth", v),
 		HookFilter: (v) => api.parse("HookFilter", v),
 		HookSort: (v) => api.parse("HookSort", v),
 		Hook: (v) => api.parse("Hook", v),
@@ -497,14 +661,33 @@ var api;
 		TLSResult: (v) => api.parse("TLSResult", v),
 		TLSRPTSuppressAddress: (v) => api.parse("TLSRPTSuppressAddress", v),
 		Dynamic: (v) => api.parse("Dynamic", v),
+		RetrySchedule: (v) => api.parse("RetrySchedule", v),
+		TLSPolicy: (v) => api.parse("TLSPolicy", v),
+		DynamicConfig: (v) => api.parse("DynamicConfig", v),
+		DKIMRotationStatus: (v) => api.parse("DKIMRotationStatus", v),
+		DKIMRotationTask: (v) => api.parse("DKIMRotationTask", v),
+		DKIMRotationEvent: (v) => api.parse("DKIMRotationEvent", v),
+		Subscriber: (v) => api.parse("Subscriber", v),
+		Message: (v) => api.parse("Message", v),
 		TLSPublicKey: (v) => api.parse("TLSPublicKey", v),
 		LoginAttempt: (v) => api.parse("LoginAttempt", v),
+		AuditFilter: (v) => api.parse("AuditFilter", v),
+		AuditEvent: (v) => api.parse("AuditEvent", v),
+		QuotaStatus: (v) => api.parse("QuotaStatus", v),
+		MTASTSRollout: (v) => api.parse("MTASTSRollout", v),
+		DomainReadiness: (v) => api.parse("DomainReadiness", v),
+		ReadinessItem: (v) => api.parse("ReadinessItem", v),
 		CSRFToken: (v) => api.parse("CSRFToken", v),
 		DMARCPolicy: (v) => api.parse("DMARCPolicy", v),
 		Align: (v) => api.parse("Align", v),
 		RUA: (v) => api.parse("RUA", v),
 		Mode: (v) => api.parse("Mode", v),
 		Localpart: (v) => api.parse("Localpart", v),
+		FailureCategory: (v) => api.parse("FailureCategory", v),
+		SenderType: (v) => api.parse("SenderType", v),
+		Override: (v) => api.parse("Override", v),
+		Status: (v) => api.parse("Status", v),
+		SignupState: (v) => api.parse("SignupState", v),
 		IP: (v) => api.parse("IP", v),
 		AuthResult: (v) => api.parse("AuthResult", v),
 	};
@@ -543,12 +726,37 @@ var api;
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Login returns a session token for the credentials, or fails with error code
-		// "user:badLogin". Call LoginPrep to get a loginToken.
-		async Login(loginToken, password) {
+		// "user:badLogin". Call LoginPrep to get a loginToken. Without username, the
+		// admin password from mox.conf is used. Otherwise username is an admin user from
+		// domains.conf. If two-factor authentication is enabled for the admin password,
+		// totp must be a code from the authenticator app, otherwise the login fails with
+		// error code "user:totpRequired".
+		async Login(loginToken, username, password, totp) {
 			const fn = "Login";
-			const paramTypes = [["string"], ["string"]];
+			const paramTypes = [["string"], ["string"], ["string"], ["string"]];
 			const returnTypes = [["CSRFToken"]];
-			const params = [loginToken, password];
+			const params = [loginToken, username, password, totp];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLoginStart returns the parameters for the browser to login with a
+		// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+		// of the browser.
+		async PasskeyLoginStart(loginToken) {
+			const fn = "PasskeyLoginStart";
+			const paramTypes = [["string"]];
+			const returnTypes = [["PasskeyGetOptions"]];
+			const params = [loginToken];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLogin returns a session token for a passkey login started with
+		// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+		// password, the passkey must verify the user, e.g. with a PIN. With the password,
+		// the passkey is the second factor.
+		async PasskeyLogin(loginToken, password, assertion) {
+			const fn = "PasskeyLogin";
+			const paramTypes = [["string"], ["string"], ["PasskeyAssertion"]];
+			const returnTypes = [["CSRFToken"]];
+			const params = [loginToken, password, assertion];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Logout invalidates the session token.
@@ -576,7 +784,8 @@ var api;
 			const params = [domainName];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		// Domains returns all configured domain names.
+		// Domains returns all configured domain names. For domain admins, only their
+		// domains are returned.
 		async Domains() {
 			const fn = "Domains";
 			const paramTypes = [];
@@ -616,7 +825,9 @@ var api;
 			const params = [domain];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		// Accounts returns the names of all configured and all disabled accounts.
+		// Accounts returns the names of all configured and all disabled accounts. For
+		// domain admins, only accounts with one of their domains as default domain are
+		// returned.
 		async Accounts() {
 			const fn = "Accounts";
 			const paramTypes = [];
@@ -676,6 +887,25 @@ var api;
 			const params = [start, end, policyDomain];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// TLSRPTStats returns statistics about failure types and trends for TLS reports
+		// overlapping with period start/end, for one or all policy domains (when domain is
+		// empty).
+		async TLSRPTStats(start, end, policyDomain) {
+			const fn = "TLSRPTStats";
+			const paramTypes = [["timestamp"], ["timestamp"], ["string"]];
+			const returnTypes = [["Stats"]];
+			const params = [start, end, policyDomain];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TLSRPTFailureTypes returns the failure types seen in TLS reports for one or
+		// all policy domains (when domain is empty), most recently seen first.
+		async TLSRPTFailureTypes(policyDomain) {
+			const fn = "TLSRPTFailureTypes";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "FailureType"]];
+			const params = [policyDomain];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// DMARCReports returns DMARC reports overlapping with period start/end, for the
 		// given domain (or all domains if empty). The reports are sorted first by period
 		// end (most recent first), then by domain.
@@ -694,6 +924,23 @@ var api;
 			const params = [domain, reportID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// DMARCFailureReports returns DMARC failure reports received during period
+		// start/end, for the given domain (or all domains if empty), most recent first.
+		async DMARCFailureReports(start, end, domain) {
+			const fn = "DMARCFailureReports";
+			const paramTypes = [["timestamp"], ["timestamp"], ["string"]];
+			const returnTypes = [["[]", "DomainFailureReport"]];
+			const params = [start, end, domain];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DMARCFailureReportID returns a single DMARC failure report.
+		async DMARCFailureReportID(domain, reportID) {
+			const fn = "DMARCFailureReportID";
+			const paramTypes = [["string"], ["int64"]];
+			const returnTypes = [["DomainFailureReport"]];
+			const params = [domain, reportID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// DMARCSummaries returns a summary of received DMARC reports overlapping with
 		// period start/end for one or all domains (when domain is empty).
 		// The returned summaries are ordered by domain name.
@@ -704,6 +951,47 @@ var api;
 			const params = [start, end, domain];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// DMARCAnalytics returns statistics and trends for DMARC reports overlapping
+		// with period start/end, for the given domain (or all domains if empty). At most
+		// 20 sources, failing sources and reporters are returned.
+		async DMARCAnalytics(start, end, domain) {
+			const fn = "DMARCAnalytics";
+			const paramTypes = [["timestamp"], ["timestamp"], ["string"]];
+			const returnTypes = [["Analytics"]];
+			const params = [start, end, domain];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Postmaster returns reputation data from Microsoft SNDS and Google Postmaster
+		// Tools for the period start/end, along with our outgoing delivery volume to
+		// those providers during the period.
+		async Postmaster(start, end) {
+			const fn = "Postmaster";
+			const paramTypes = [["timestamp"], ["timestamp"]];
+			const returnTypes = [["PostmasterData"]];
+			const params = [start, end];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderReputations returns the server-wide reputation of sending domains and
+		// IPs of incoming deliveries, most recently seen first. Typ can be "domain", "ip"
+		// or empty for both. If overrides is set, only senders with an override are
+		// returned. At most 1000 senders are returned.
+		async SenderReputations(typ, overrides) {
+			const fn = "SenderReputations";
+			const paramTypes = [["string"], ["bool"]];
+			const returnTypes = [["[]", "Sender"]];
+			const params = [typ, overrides];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderReputationOverride sets an override for the server-wide reputation of a
+		// sending domain or IP, or clears it if override is empty. A domain is changed to
+		// its organizational domain, an IPv6 address to its /64 network.
+		async SenderReputationOverride(typ, value, override) {
+			const fn = "SenderReputationOverride";
+			const paramTypes = [["SenderType"], ["string"], ["Override"]];
+			const returnTypes = [];
+			const params = [typ, value, override];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// LookupIP does a reverse lookup of ip.
 		async LookupIP(ip) {
 			const fn = "LookupIP";
@@ -712,6 +1000,30 @@ var api;
 			const params = [ip];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// SPFAnalyze looks up the SPF record of a domain and expands it fully, following
+		// includes and redirects. The analysis reports the number of DNS lookups against
+		// the limit of 10, void lookups, and problems such as overlapping IP networks.
+		// Useful for our own domains, and for debugging delivery to remote domains.
+		// 
+		// If ip is not empty, it is used for expanding macros, and is evaluated against
+		// the record, with the resulting status and matching mechanism returned.
+		async SPFAnalyze(domain, ip) {
+			const fn = "SPFAnalyze";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [["Analysis"], ["Status"], ["string"], ["string"]];
+			const params = [domain, ip];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DANEHostStatus returns the DANE TLSA records for the keys of the current and
+		// next TLS certificates of the mail host, and checks them against the records in
+		// DNS, warning about key rollovers that would break DANE verification.
+		async DANEHostStatus() {
+			const fn = "DANEHostStatus";
+			const paramTypes = [];
+			const returnTypes = [["DANEHost"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// DNSBLStatus returns the IPs from which outgoing connections may be made and
 		// their current status in DNSBLs that are configured. The IPs are typically the
 		// configured listen IPs, or otherwise IPs on the machines network interfaces, with
@@ -767,6 +1079,17 @@ var api;
 			const params = [accountName, address];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// AccountsImport adds multiple accounts from text in CSV or JSON format, see
+		// admin.ParseAccountImports. Either all accounts are added or none. The results
+		// have an error for each account that cannot be added, or the initial password
+		// for each added account. With dryRun, the accounts are only validated.
+		async AccountsImport(text, dryRun, requireChange) {
+			const fn = "AccountsImport";
+			const paramTypes = [["string"], ["bool"], ["bool"]];
+			const returnTypes = [["[]", "AccountImportResult"]];
+			const params = [text, dryRun, requireChange];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// AccountRemove removes an existing account and reloads the configuration.
 		async AccountRemove(accountName) {
 			const fn = "AccountRemove";
@@ -801,6 +1124,258 @@ var api;
 			const params = [accountName, password];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// PasswordRequireChange marks the password of an account as expired. A new
+		// password must be set through the account web interface before logging in with
+		// other protocols. Sessions of the account are removed.
+		async PasswordRequireChange(accountName) {
+			const fn = "PasswordRequireChange";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountSendSuspension returns the suspension of sending for an account after
+		// an anomaly in outgoing messages, or nil if sending is not suspended.
+		async AccountSendSuspension(accountName) {
+			const fn = "AccountSendSuspension";
+			const paramTypes = [["string"]];
+			const returnTypes = [["nullable", "SendSuspension"]];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountSendResume resumes sending for an account that was suspended after an
+		// anomaly in outgoing messages.
+		async AccountSendResume(accountName) {
+			const fn = "AccountSendResume";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountStorage returns the number of messages in an account, and their total
+		// uncompressed and stored sizes, see MessageCompression.
+		async AccountStorage(accountName) {
+			const fn = "AccountStorage";
+			const paramTypes = [["string"]];
+			const returnTypes = [["MessageStorage"]];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountPasswordPolicySave sets the password policy for an account, with
+		// requirements for passwords, expiry and lockout after failed logins. A nil
+		// policy removes it.
+		async AccountPasswordPolicySave(accountName, policy) {
+			const fn = "AccountPasswordPolicySave";
+			const paramTypes = [["string"], ["nullable", "PasswordPolicy"]];
+			const returnTypes = [];
+			const params = [accountName, policy];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountTwoFactorEnabled returns whether the account has enabled two-factor
+		// authentication.
+		async AccountTwoFactorEnabled(accountName) {
+			const fn = "AccountTwoFactorEnabled";
+			const paramTypes = [["string"]];
+			const returnTypes = [["bool"]];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountRequireTwoFactorSave sets whether the account must use two-factor
+		// authentication.
+		async AccountRequireTwoFactorSave(accountName, require0) {
+			const fn = "AccountRequireTwoFactorSave";
+			const paramTypes = [["string"], ["bool"]];
+			const returnTypes = [];
+			const params = [accountName, require0];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountTOTPRemove disables two-factor authentication for an account, e.g.
+		// after the account lost its authenticator app. If two-factor authentication is
+		// required, the account has to set it up again at the next login.
+		async AccountTOTPRemove(accountName) {
+			const fn = "AccountTOTPRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountJunkFilterStats returns the number of trained messages and known words
+		// of the junk filter of an account, and of the shared junk filter of its domain,
+		// if configured.
+		async AccountJunkFilterStats(accountName) {
+			const fn = "AccountJunkFilterStats";
+			const paramTypes = [["string"]];
+			const returnTypes = [["JunkFilterStats"]];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountJunkVerdicts returns the most recently delivered messages of an account
+		// with the outcome of their junk analysis, for debugging misclassifications. If
+		// junkOnly is set, only messages that were rejected or are marked as junk are
+		// returned. If limit is greater than 0, at most limit messages are returned.
+		async AccountJunkVerdicts(accountName, junkOnly, limit) {
+			const fn = "AccountJunkVerdicts";
+			const paramTypes = [["string"], ["bool"], ["int32"]];
+			const returnTypes = [["[]", "JunkVerdict"]];
+			const params = [accountName, junkOnly, limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountJunkFilterRetrain removes the junk filter of an account and trains a new
+		// junk filter from scratch with all messages marked as junk or not junk. The
+		// account is locked while retraining. Returns the number of messages in the
+		// account and the number of trained messages.
+		async AccountJunkFilterRetrain(accountName) {
+			const fn = "AccountJunkFilterRetrain";
+			const paramTypes = [["string"]];
+			const returnTypes = [["int32"], ["int32"]];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountPasskeys returns the passkeys registered by an account.
+		async AccountPasskeys(accountName) {
+			const fn = "AccountPasskeys";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "Passkey"]];
+			const params = [accountName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountPasskeyRemove removes a passkey of an account, e.g. for a lost device.
+		async AccountPasskeyRemove(accountName, credentialID) {
+			const fn = "AccountPasskeyRemove";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [];
+			const params = [accountName, credentialID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainRequireTwoFactorSave sets whether accounts with the domain as their
+		// default domain must use two-factor authentication.
+		async DomainRequireTwoFactorSave(domainName, require0) {
+			const fn = "DomainRequireTwoFactorSave";
+			const paramTypes = [["string"], ["bool"]];
+			const returnTypes = [];
+			const params = [domainName, require0];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminTOTPEnabled returns whether admin logins require a code from an
+		// authenticator app.
+		async AdminTOTPEnabled() {
+			const fn = "AdminTOTPEnabled";
+			const paramTypes = [];
+			const returnTypes = [["bool"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminTOTPSetupStart starts enabling two-factor authentication for admin
+		// logins, returning the parameters for the authenticator app. Call
+		// AdminTOTPSetupConfirm with a code from the app to enable it.
+		async AdminTOTPSetupStart() {
+			const fn = "AdminTOTPSetupStart";
+			const paramTypes = [];
+			const returnTypes = [["TOTPSetup"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminTOTPSetupConfirm enables two-factor authentication for admin logins
+		// after verifying a code from the authenticator app.
+		async AdminTOTPSetupConfirm(code) {
+			const fn = "AdminTOTPSetupConfirm";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [code];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminTOTPRemove disables two-factor authentication for admin logins.
+		async AdminTOTPRemove() {
+			const fn = "AdminTOTPRemove";
+			const paramTypes = [];
+			const returnTypes = [];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminPasskeys returns the passkeys registered for admin logins.
+		async AdminPasskeys() {
+			const fn = "AdminPasskeys";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Passkey"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminPasskeyRegisterStart starts registration of a new passkey for admin
+		// logins, returning the parameters for the browser. Complete the registration
+		// with AdminPasskeyRegister.
+		async AdminPasskeyRegisterStart() {
+			const fn = "AdminPasskeyRegisterStart";
+			const paramTypes = [];
+			const returnTypes = [["PasskeyCreateOptions"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminPasskeyRegister completes registration of a passkey for admin logins with
+		// the response from the browser. Name describes the passkey, e.g. the device.
+		async AdminPasskeyRegister(name, clientDataJSON, attestationObject) {
+			const fn = "AdminPasskeyRegister";
+			const paramTypes = [["string"], ["nullable", "string"], ["nullable", "string"]];
+			const returnTypes = [["Passkey"]];
+			const params = [name, clientDataJSON, attestationObject];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminPasskeyRemove removes a passkey for admin logins.
+		async AdminPasskeyRemove(credentialID) {
+			const fn = "AdminPasskeyRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [credentialID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminSelf returns the admin user of the session, with an empty name for logins
+		// with the admin password.
+		async AdminSelf() {
+			const fn = "AdminSelf";
+			const paramTypes = [];
+			const returnTypes = [["string"], ["AdminUser"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminUsers returns the named admin users from domains.conf.
+		async AdminUsers() {
+			const fn = "AdminUsers";
+			const paramTypes = [];
+			const returnTypes = [["{}", "AdminUser"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminUserAdd adds an admin user with a role, and domains for domain admins.
+		async AdminUserAdd(name, role, domains, password) {
+			const fn = "AdminUserAdd";
+			const paramTypes = [["string"], ["string"], ["[]", "string"], ["string"]];
+			const returnTypes = [];
+			const params = [name, role, domains, password];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminUserUpdate changes the role and domains of an admin user.
+		async AdminUserUpdate(name, role, domains) {
+			const fn = "AdminUserUpdate";
+			const paramTypes = [["string"], ["string"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [name, role, domains];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminUserSetPassword sets a new password for an admin user.
+		async AdminUserSetPassword(name, password) {
+			const fn = "AdminUserSetPassword";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [];
+			const params = [name, password];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AdminUserRemove removes an admin user.
+		async AdminUserRemove(name) {
+			const fn = "AdminUserRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [name];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// AccountSettingsSave set new settings for an account that only an admin can set.
 		async AccountSettingsSave(accountName, maxOutgoingMessagesPerDay, maxFirstTimeRecipientsPerDay, maxMsgSize, firstTimeSenderDelay, noCustomPassword) {
 			const fn = "AccountSettingsSave";
@@ -817,6 +1392,81 @@ var api;
 			const params = [accountName, loginDisabled];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// AccountImpersonate starts a webmail session as an account, for troubleshooting.
+		// The returned single-use token must be opened within 1 minute, at
+		// "impersonate?token=..." of the webmail interface, e.g. at webmailPath on the
+		// same host. The session ends after the given number of minutes, at most 8
+		// hours. With readOnly, no changes can be made through the session. The start of
+		// the session and all API calls made through it are recorded in the audit log, and
+		// the webmail interface shows a banner while impersonating.
+		async AccountImpersonate(accountName, readOnly, minutes) {
+			const fn = "AccountImpersonate";
+			const paramTypes = [["string"], ["bool"], ["int32"]];
+			const returnTypes = [["string"], ["string"]];
+			const params = [accountName, readOnly, minutes];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SignupRequests returns all self-service signup requests, most recent first.
+		async SignupRequests() {
+			const fn = "SignupRequests";
+			const paramTypes = [];
+			const returnTypes = [["[]", "SignupRequest"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SignupApprove approves a pending signup request, creating the account and
+		// notifying the requester.
+		async SignupApprove(id) {
+			const fn = "SignupApprove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [id];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SignupReject rejects a pending signup request, notifying the requester with
+		// the optional reason.
+		async SignupReject(id, reason) {
+			const fn = "SignupReject";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [];
+			const params = [id, reason];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SignupInvites returns all signup invitations, most recent first.
+		async SignupInvites() {
+			const fn = "SignupInvites";
+			const paramTypes = [];
+			const returnTypes = [["[]", "SignupInvite"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SignupInviteAdd adds an invitation for signups at a domain, valid for the
+		// given number of days. The token is only returned once, the invitation link is
+		// "?invite=..." at signupPath.
+		async SignupInviteAdd(domainName, maxUses, days, note) {
+			const fn = "SignupInviteAdd";
+			const paramTypes = [["string"], ["int32"], ["int32"], ["string"]];
+			const returnTypes = [["string"], ["string"]];
+			const params = [domainName, maxUses, days, note];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SignupInviteRemove removes a signup invitation.
+		async SignupInviteRemove(id) {
+			const fn = "SignupInviteRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [id];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainSignupSave saves the self-service signup settings for a domain. A nil
+		// signup disables signups for the domain.
+		async DomainSignupSave(domainName, signup) {
+			const fn = "DomainSignupSave";
+			const paramTypes = [["string"], ["nullable", "DomainSignup"]];
+			const returnTypes = [];
+			const params = [domainName, signup];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// ClientConfigsDomain returns configurations for email clients, IMAP and
 		// Submission (SMTP) for the domain.
 		async ClientConfigsDomain(domain) {
@@ -860,7 +1510,17 @@ var api;
 			const params = [holdRuleID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		// QueueList returns the messages currently in the outgoing queue.
+		// QueueHoldRuleResume removes a hold rule and takes matching messages in the
+		// queue off hold, unless they also match another hold rule.
+		async QueueHoldRuleResume(holdRuleID) {
+			const fn = "QueueHoldRuleResume";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["int32"]];
+			const params = [holdRuleID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueList returns the messages currently in the outgoing queue. SMTP
+		// transcripts are not included, see QueueMsgTranscript.
 		async QueueList(filter, sort) {
 			const fn = "QueueList";
 			const paramTypes = [["Filter"], ["Sort"]];
@@ -868,6 +1528,24 @@ var api;
 			const params = [filter, sort];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// QueueCount returns the number of messages in the outgoing queue matching
+		// filter, ignoring its Max.
+		async QueueCount(filter) {
+			const fn = "QueueCount";
+			const paramTypes = [["Filter"]];
+			const returnTypes = [["int32"]];
+			const params = [filter];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueMsgTranscript returns the result of the most recent delivery attempt of
+		// a message in the queue that has an SMTP transcript.
+		async QueueMsgTranscript(id) {
+			const fn = "QueueMsgTranscript";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["MsgResult"]];
+			const params = [id];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// QueueNextAttemptSet sets a new time for next delivery attempt of matching
 		// messages from the queue.
 		async QueueNextAttemptSet(filter, minutes) {
@@ -928,6 +1606,16 @@ var api;
 			const params = [filter, transport];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// QueueMsgRewrite changes the recipient, transport, and/or the From and Subject
+		// headers of a message in the queue, and schedules it for immediate delivery. The
+		// changes are kept with the message as audit trail.
+		async QueueMsgRewrite(id, rewrite) {
+			const fn = "QueueMsgRewrite";
+			const paramTypes = [["int64"], ["MsgRewrite"]];
+			const returnTypes = [["Msg"]];
+			const params = [id, rewrite];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// RetiredList returns messages retired from the queue (delivery could
 		// have succeeded or failed).
 		async RetiredList(filter, sort) {
@@ -937,6 +1625,60 @@ var api;
 			const params = [filter, sort];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// QueueDeadList returns messages in the dead-letter queue, for which delivery
+		// failed permanently.
+		async QueueDeadList(filter) {
+			const fn = "QueueDeadList";
+			const paramTypes = [["DeadFilter"]];
+			const returnTypes = [["[]", "MsgDead"]];
+			const params = [filter];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueDeadRecipientSet changes the recipient address of a message in the
+		// dead-letter queue.
+		async QueueDeadRecipientSet(id, address) {
+			const fn = "QueueDeadRecipientSet";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [];
+			const params = [id, address];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueDeadRequeue adds matching messages from the dead-letter queue back to
+		// the queue for delivery.
+		async QueueDeadRequeue(filter) {
+			const fn = "QueueDeadRequeue";
+			const paramTypes = [["DeadFilter"]];
+			const returnTypes = [["int32"]];
+			const params = [filter];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueDeadRemove removes matching messages from the dead-letter queue.
+		async QueueDeadRemove(filter) {
+			const fn = "QueueDeadRemove";
+			const paramTypes = [["DeadFilter"]];
+			const returnTypes = [["int32"]];
+			const params = [filter];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueHostHealthList returns the delivery health of remote hosts. Hosts in a
+		// cooldown period after failures are tried after other hosts with the same MX
+		// preference.
+		async QueueHostHealthList() {
+			const fn = "QueueHostHealthList";
+			const paramTypes = [];
+			const returnTypes = [["[]", "HostHealth"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// QueueHostHealthReset removes the delivery health record for a remote host,
+		// ending its cooldown period.
+		async QueueHostHealthReset(host) {
+			const fn = "QueueHostHealthReset";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [host];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// HookQueueSize returns the number of webhooks still to be delivered.
 		async HookQueueSize() {
 			const fn = "HookQueueSize";
@@ -1186,6 +1928,45 @@ var api;
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// ConfigDynamicGet returns the dynamic config, as structured data and in
+		// domains.conf format, with a hash to pass to ConfigDynamicApply.
+		async ConfigDynamicGet() {
+			const fn = "ConfigDynamicGet";
+			const paramTypes = [];
+			const returnTypes = [["DynamicConfig"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ConfigDynamicValidate returns the errors for the config as it would be
+		// checked by ConfigDynamicApply. No errors means the config is valid.
+		async ConfigDynamicValidate(c) {
+			const fn = "ConfigDynamicValidate";
+			const paramTypes = [["Dynamic"]];
+			const returnTypes = [["[]", "string"]];
+			const params = [c];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ConfigDynamicDiff returns the changes to domains.conf that applying the config
+		// would make, as unified diff. Empty if there are no changes.
+		async ConfigDynamicDiff(c) {
+			const fn = "ConfigDynamicDiff";
+			const paramTypes = [["Dynamic"]];
+			const returnTypes = [["string"]];
+			const params = [c];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ConfigDynamicApply validates the config, and writes and activates it.
+		// baseHash must be the hash from ConfigDynamicGet for the config the changes are
+		// based on, the call fails if the config has been changed since. If the written
+		// domains.conf cannot be loaded, the previous config is restored. The new config
+		// is returned.
+		async ConfigDynamicApply(baseHash, c) {
+			const fn = "ConfigDynamicApply";
+			const paramTypes = [["string"], ["Dynamic"]];
+			const returnTypes = [["DynamicConfig"]];
+			const params = [baseHash, c];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// AccountRoutesSave saves routes for an account.
 		async AccountRoutesSave(accountName, routes) {
 			const fn = "AccountRoutesSave";
@@ -1202,6 +1983,63 @@ var api;
 			const params = [domainName, routes];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// AccountOutgoingFooterSave saves the footer added to outgoing messages of an
+		// account. A nil footer removes it.
+		async AccountOutgoingFooterSave(accountName, footer) {
+			const fn = "AccountOutgoingFooterSave";
+			const paramTypes = [["string"], ["nullable", "OutgoingFooter"]];
+			const returnTypes = [];
+			const params = [accountName, footer];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainOutgoingFooterSave saves the footer added to outgoing messages from a
+		// domain. A nil footer removes it.
+		async DomainOutgoingFooterSave(domainName, footer) {
+			const fn = "DomainOutgoingFooterSave";
+			const paramTypes = [["string"], ["nullable", "OutgoingFooter"]];
+			const returnTypes = [];
+			const params = [domainName, footer];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AccountSpamActionsSave saves the spam actions of the junk filter of an
+		// account. The account must have a junk filter. Zero values are unset, falling
+		// back to the spam actions of the domain of the account.
+		async AccountSpamActionsSave(accountName, spamActions) {
+			const fn = "AccountSpamActionsSave";
+			const paramTypes = [["string"], ["SpamActions"]];
+			const returnTypes = [];
+			const params = [accountName, spamActions];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainSpamActionsSave saves the default spam actions for accounts with the
+		// domain as default domain. A nil value removes them.
+		async DomainSpamActionsSave(domainName, spamActions) {
+			const fn = "DomainSpamActionsSave";
+			const paramTypes = [["string"], ["nullable", "SpamActions"]];
+			const returnTypes = [];
+			const params = [domainName, spamActions];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainSpamtrapsSave saves the localparts of spamtrap addresses of a domain.
+		// Messages to spamtraps are not delivered, but update the server-wide sender
+		// reputation and train the shared junk filter of the domain.
+		async DomainSpamtrapsSave(domainName, localparts) {
+			const fn = "DomainSpamtrapsSave";
+			const paramTypes = [["string"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [domainName, localparts];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainMessageTemplatesSave saves the message templates of a domain, shared
+		// with all accounts with an address in the domain for composing messages in the
+		// webmail.
+		async DomainMessageTemplatesSave(domainName, templates) {
+			const fn = "DomainMessageTemplatesSave";
+			const paramTypes = [["string"], ["[]", "MessageTemplate"]];
+			const returnTypes = [];
+			const params = [domainName, templates];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// RoutesSave saves global routes.
 		async RoutesSave(routes) {
 			const fn = "RoutesSave";
@@ -1273,6 +2111,24 @@ var api;
 			const params = [domainName, selector, algorithm, hash, headerRelaxed, bodyRelaxed, seal, headers, lifetime];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// DomainDKIMRotation returns the state of automatic DKIM key rotation for a
+		// domain, with DNS changes the administrator must make and the rotation history.
+		async DomainDKIMRotation(domainName) {
+			const fn = "DomainDKIMRotation";
+			const paramTypes = [["string"]];
+			const returnTypes = [["DKIMRotationStatus"]];
+			const params = [domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainDKIMRecordsRemoved marks the DNS records of old DKIM selectors
+		// removed by rotation as removed from DNS by the administrator.
+		async DomainDKIMRecordsRemoved(domainName) {
+			const fn = "DomainDKIMRecordsRemoved";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// DomainDKIMRemove removes a DKIM selector for a domain.
 		async DomainDKIMRemove(domainName, selector) {
 			const fn = "DomainDKIMRemove";
@@ -1329,11 +2185,72 @@ var api;
 			const params = [aliaslp, domainName, addresses];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
-		async AliasAddressesRemove(aliaslp, domainName, addresses) {
-			const fn = "AliasAddressesRemove";
-			const paramTypes = [["string"], ["string"], ["[]", "string"]];
-			const returnTypes = [];
-			const params = [aliaslp, domainName, addresses];
+		async AliasAddressesRemove(aliaslp, domainName, addresses) {
+			const fn = "AliasAddressesRemove";
+			const paramTypes = [["string"], ["string"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [aliaslp, domainName, addresses];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AliasListSave saves the distribution list settings of an alias. PostPolicy is
+		// one of "members", "anyone", "moderated", or empty to use PostPublic. Owners are
+		// addresses of local accounts that can manage the members.
+		async AliasListSave(aliaslp, domainName, postPolicy, listHeaders, owners) {
+			const fn = "AliasListSave";
+			const paramTypes = [["string"], ["string"], ["string"], ["bool"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [aliaslp, domainName, postPolicy, listHeaders, owners];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// AliasMailingListSave saves the mailing list settings of an alias. With a nil
+		// list, the alias is no longer a mailing list.
+		async AliasMailingListSave(aliaslp, domainName, list) {
+			const fn = "AliasMailingListSave";
+			const paramTypes = [["string"], ["string"], ["nullable", "AliasList"]];
+			const returnTypes = [];
+			const params = [aliaslp, domainName, list];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ListSubscribers returns the subscribers of a mailing list, including those that
+		// haven't confirmed their subscription yet.
+		async ListSubscribers(aliaslp, domainName) {
+			const fn = "ListSubscribers";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [["[]", "Subscriber"]];
+			const params = [aliaslp, domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ListSubscriberAdd adds a confirmed subscriber to a mailing list.
+		async ListSubscriberAdd(aliaslp, domainName, address, digest) {
+			const fn = "ListSubscriberAdd";
+			const paramTypes = [["string"], ["string"], ["string"], ["bool"]];
+			const returnTypes = [];
+			const params = [aliaslp, domainName, address, digest];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ListSubscriberRemove removes a subscriber from a mailing list.
+		async ListSubscriberRemove(aliaslp, domainName, subscriberID) {
+			const fn = "ListSubscriberRemove";
+			const paramTypes = [["string"], ["string"], ["int64"]];
+			const returnTypes = [];
+			const params = [aliaslp, domainName, subscriberID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ListArchive returns the archived messages of a mailing list, most recent
+		// first.
+		async ListArchive(aliaslp, domainName) {
+			const fn = "ListArchive";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [["[]", "Message"]];
+			const params = [aliaslp, domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ListArchiveMessage returns an archived message of a mailing list as text.
+		async ListArchiveMessage(aliaslp, domainName, messageID) {
+			const fn = "ListArchiveMessage";
+			const paramTypes = [["string"], ["string"], ["int64"]];
+			const returnTypes = [["string"]];
+			const params = [aliaslp, domainName, messageID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		async TLSPublicKeys(accountOpt) {
@@ -1350,6 +2267,77 @@ var api;
 			const params = [accountName, limit];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// AuditList returns events from the audit log matching filter, most recent first.
+		// If limit is greater than 0, at most limit events are returned.
+		async AuditList(filter, limit) {
+			const fn = "AuditList";
+			const paramTypes = [["AuditFilter"], ["int32"]];
+			const returnTypes = [["[]", "AuditEvent"]];
+			const params = [filter, limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TLSCertsReload reloads the TLS certificates of listeners with static
+		// keys/certificates or certificate sources, returning the names of the listeners
+		// with reloaded certificates.
+		async TLSCertsReload() {
+			const fn = "TLSCertsReload";
+			const paramTypes = [];
+			const returnTypes = [["[]", "string"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// StorageUsage returns the storage use of accounts relative to their quota,
+		// largest first. If limit is greater than 0, at most limit accounts are returned.
+		async StorageUsage(limit) {
+			const fn = "StorageUsage";
+			const paramTypes = [["int32"]];
+			const returnTypes = [["[]", "QuotaStatus"]];
+			const params = [limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainMTASTSPolicySave saves the MTA-STS policy for a domain with the given
+		// mode, max age and MX hosts/patterns. A new policy ID is set if the policy
+		// changed. For domains with DNS provisioning, the MTA-STS DNS record is updated
+		// with the new policy ID. The (new) policy ID is returned.
+		async DomainMTASTSPolicySave(domainName, mode, maxAge, mx) {
+			const fn = "DomainMTASTSPolicySave";
+			const paramTypes = [["string"], ["Mode"], ["int64"], ["[]", "string"]];
+			const returnTypes = [["string"]];
+			const params = [domainName, mode, maxAge, mx];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainMTASTSRollout returns the state of the staged rollout of the MTA-STS
+		// policy for a domain, based on the received TLS reports.
+		async DomainMTASTSRollout(domainName) {
+			const fn = "DomainMTASTSRollout";
+			const paramTypes = [["string"]];
+			const returnTypes = [["MTASTSRollout"]];
+			const params = [domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainMTASTSPromote moves the MTA-STS policy for a domain to the next mode of
+		// the staged rollout: from no policy or mode none to testing, and from testing to
+		// enforce, the latter only if the TLS reports show the policy is ready. When
+		// enforcing, the max age is raised to at least a week. The new policy ID is
+		// returned.
+		async DomainMTASTSPromote(domainName) {
+			const fn = "DomainMTASTSPromote";
+			const paramTypes = [["string"]];
+			const returnTypes = [["string"]];
+			const params = [domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DomainReadiness returns the readiness checklist for a domain, with DNS records
+		// to add and the status of each check. A check is started in the background if
+		// none is in progress and the latest result is older than a minute. Clients
+		// should poll while Checking is set or the domain is not ready.
+		async DomainReadiness(domainName) {
+			const fn = "DomainReadiness";
+			const paramTypes = [["string"]];
+			const returnTypes = [["DomainReadiness"]];
+			const params = [domainName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 	}
 	api.Client = Client;
 	api.defaultBaseURL = (function () {
@@ -1705,12 +2693,31 @@ var api;
 let moxversion;
 let moxgoos;
 let moxgoarch;
+// Admin user of the session, name is empty for login with the admin password.
+let adminName;
+let adminUser;
 const login = async (reason) => {
 	return new Promise((resolve, _) => {
 		const origFocus = document.activeElement;
 		let reasonElem;
 		let fieldset;
+		let username;
 		let password;
+		let totpLabel;
+		let totp;
+		const loggedIn = (token) => {
+			try {
+				window.localStorage.setItem('webadmincsrftoken', token);
+			}
+			catch (err) {
+				console.log('saving csrf token in localStorage', err);
+			}
+			root.remove();
+			if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
+				origFocus.focus();
+			}
+			resolve(token);
+		};
 		const root = dom.div(style({ position: 'absolute', top: 0, right: 0, bottom: 0, left: 0, backgroundColor: '#eee', display: 'flex', alignItems: 'center', justifyContent: 'center', zIndex: '1', animation: 'fadein .15s ease-in' }), dom.div(style({ display: 'flex', flexDirection: 'column', alignItems: 'center' }), reasonElem = reason ? dom.div(style({ marginBottom: '2ex', textAlign: 'center' }), reason) : dom.div(), dom.div(style({ backgroundColor: 'white', borderRadius: '.25em', padding: '1em', boxShadow: '0 0 20px rgba(0, 0, 0, 0.1)', border: '1px solid #ddd', maxWidth: '95vw', overflowX: 'auto', maxHeight: '95vh', overflowY: 'auto', marginBottom: '20vh' }), dom.form(async function submit(e) {
 			e.preventDefault();
 			e.stopPropagation();
@@ -1718,31 +2725,97 @@ const login = async (reason) => {
 			try {
 				fieldset.disabled = true;
 				const loginToken = await client.LoginPrep();
-				const token = await client.Login(loginToken, password.value);
-				try {
-					window.localStorage.setItem('webadmincsrftoken', token);
-				}
-				catch (err) {
-					console.log('saving csrf token in localStorage', err);
-				}
-				root.remove();
-				if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
-					origFocus.focus();
-				}
-				resolve(token);
+				const token = await client.Login(loginToken, username.value, password.value, totp.value);
+				loggedIn(token);
 			}
 			catch (err) {
 				console.log('login error', err);
+				if (err.code === 'user:totpRequired') {
+					// Password is valid, ask for the code from the authenticator app.
+					totpLabel.style.display = 'block';
+					totp.required = true;
+					fieldset.disabled = false;
+					totp.focus();
+					return;
+				}
 				window.alert('Error: ' + errmsg(err));
 			}
 			finally {
 				fieldset.disabled = false;
 			}
-		}, fieldset = dom.fieldset(dom.h1('Admin'), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Password', style({ marginBottom: '.5ex' })), password = dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required(''))), dom.div(style({ textAlign: 'center' }), dom.submitbutton('Login')))))));
+		}, fieldset = dom.fieldset(dom.h1('Admin'), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Username', style({ marginBottom: '.5ex' })), username = dom.input(attr.autocomplete('username'), attr.placeholder('Empty for admin password'), attr.title('Name of an admin user. Leave empty to login with the admin password.'))), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Password', style({ marginBottom: '.5ex' })), password = dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required(''))), totpLabel = dom.label(style({ display: 'none', marginBottom: '2ex' }), dom.div('Two-factor authentication code', style({ marginBottom: '.5ex' })), totp = dom.input(attr.autocomplete('one-time-code'))), dom.div(style({ textAlign: 'center' }), dom.submitbutton('Login'), window.PublicKeyCredential ? [
+			' ',
+			dom.clickbutton('Login with passkey', attr.title('Login with a passkey registered for the admin, without password. Or, after entering the password, use the passkey as second factor.'), async function click() {
+				reasonElem.remove();
+				try {
+					fieldset.disabled = true;
+					const loginToken = await client.LoginPrep();
+					const opts = await client.PasskeyLoginStart(loginToken);
+					const assertion = await passkeyGet(opts);
+					const token = await client.PasskeyLogin(loginToken, password.value, assertion);
+					loggedIn(token);
+				}
+				catch (err) {
+					console.log('passkey login error', err);
+					window.alert('Error: ' + errmsg(err));
+				}
+				finally {
+					fieldset.disabled = false;
+				}
+			}),
+		] : []))))));
 		document.body.appendChild(root);
 		password.focus();
 	});
 };
+// The WebAuthn browser API uses ArrayBuffers, the API base64 strings.
+const base64ToBuffer = (s) => Uint8Array.from(window.atob(s || ''), c => c.charCodeAt(0)).buffer;
+const bufferToBase64 = (buf) => window.btoa(String.fromCharCode(...new Uint8Array(buf)));
+// passkeyGet lets the user select a passkey in the browser, returning the signed
+// response for the login.
+const passkeyGet = async (opts) => {
+	const cred = await window.navigator.credentials.get({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rpId: opts.RPID,
+			timeout: opts.Timeout,
+			userVerification: 'preferred',
+		},
+	});
+	if (!cred) {
+		throw new Error('no passkey selected');
+	}
+	const resp = cred.response;
+	return {
+		CredentialID: bufferToBase64(cred.rawId),
+		ClientDataJSON: bufferToBase64(resp.clientDataJSON),
+		AuthenticatorData: bufferToBase64(resp.authenticatorData),
+		Signature: bufferToBase64(resp.signature),
+	};
+};
+// passkeyCreate lets the user create a new passkey in the browser or on a
+// security key, returning the client data and attestation object for the
+// registration.
+const passkeyCreate = async (opts) => {
+	const cred = await window.navigator.credentials.create({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rp: { id: opts.RPID, name: opts.RPName },
+			user: { id: base64ToBuffer(opts.UserID), name: opts.UserName, displayName: opts.UserName },
+			pubKeyCredParams: (opts.Algorithms || []).map(alg => ({ type: 'public-key', alg: alg })),
+			excludeCredentials: (opts.ExcludeCredentials || []).map(id => ({ type: 'public-key', id: base64ToBuffer(id) })),
+			// Passkeys must be discoverable, logins don't specify credentials.
+			authenticatorSelection: { residentKey: 'required', userVerification: 'preferred' },
+			attestation: 'none',
+			timeout: opts.Timeout,
+		},
+	});
+	if (!cred) {
+		throw new Error('no passkey created');
+	}
+	const resp = cred.response;
+	return [bufferToBase64(resp.clientDataJSON), bufferToBase64(resp.attestationObject)];
+};
 // Popup shows kids in a centered div with white background on top of a
 // transparent overlay on top of the window. Clicking the overlay or hitting
 // Escape closes the popup. Scrollbars are automatically added to the div with
@@ -1969,10 +3042,13 @@ const formatSize = (n) => {
 	return n + ' bytes';
 };
 const index = async () => {
+	// Domain admins only have access to their domains and accounts.
+	const domainAdmin = adminUser.Role === 'domainadmin';
+	const fullAdmin = adminUser.Role === 'admin';
 	const [domains, queueSize, hooksQueueSize, checkUpdatesEnabled, [accounts, accountsDisabled]] = await Promise.all([
 		client.Domains(),
-		client.QueueSize(),
-		client.HookQueueSize(),
+		domainAdmin ? 0 : client.QueueSize(),
+		domainAdmin ? 0 : client.HookQueueSize(),
 		client.CheckUpdatesEnabled(),
 		client.Accounts(),
 	]);
@@ -1984,21 +3060,54 @@ const index = async () => {
 	let recvIDFieldset;
 	let recvID;
 	let cidElem;
-	return dom.div(crumbs('Mox Admin'), checkUpdatesEnabled ? [] : dom.p(box(yellow, 'Warning: Checking for updates has not been enabled in mox.conf (CheckUpdates: true).', dom.br(), 'Make sure you stay up to date through another mechanism!', dom.br(), 'You have a responsibility to keep the internet-connected software you run up to date and secure!', dom.br(), 'See ', link('https://updates.xmox.nl/changelog'))), dom.p(dom.a('Accounts', attr.href('#accounts')), dom.br(), dom.a('Queue', attr.href('#queue')), ' (' + queueSize + ')', dom.br(), dom.a('Webhook queue', attr.href('#webhookqueue')), ' (' + hooksQueueSize + ')', dom.br()), dom.h2('Domains'), (domains || []).length === 0 ? box(red, 'No domains') :
-		dom.ul((domains || []).map(d => dom.li(dom.a(attr.href('#domains/' + domainName(d.Domain)), domainString(d.Domain)), d.Disabled ? ' (disabled)' : []))), dom.br(), dom.h2('Add domain'), dom.form(async function submit(e) {
-		e.preventDefault();
-		e.stopPropagation();
-		await check(fieldset, client.DomainAdd(disabled.checked, domain.value, account.value, localpart.value));
-		window.location.hash = '#domains/' + domain.value;
-	}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), dom.span('Domain', attr.title('Domain for incoming/outgoing email to add to mox. Can also be a subdomain of a domain already configured.')), dom.br(), domain = dom.input(attr.required(''))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Postmaster/reporting account', attr.title('Account that is considered the owner of this domain. If the account does not yet exist, it will be created and a a localpart is required for the initial email address.')), dom.br(), account = dom.input(attr.required(''), attr.list('accountList')), dom.datalist(attr.id('accountList'), (accounts || []).map(a => dom.option(attr.value(a), a + (accountsDisabled?.includes(a) ? ' (disabled)' : ''))))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Localpart (if new account)', attr.title('Must be set if and only if account does not yet exist. A localpart is the part before the "@"-sign of an email address. An account requires an email address, so creating a new account for a domain requires a localpart to form an initial email address.')), dom.br(), localpart = dom.input()), ' ', dom.label(disabled = dom.input(attr.type('checkbox')), ' Disabled', attr.title('Disabled domains do fetch new certificates with ACME and do not accept incoming or outgoing messages involving the domain. Accounts and addresses referencing a disabled domain can be created. USeful during/before migrations.')), ' ', dom.submitbutton('Add domain', attr.title('Domain will be added and the config reloaded. Add the required DNS records after adding the domain.')))), dom.br(), dom.h2('Reports'), dom.div(dom.a('DMARC', attr.href('#dmarc/reports'))), dom.div(dom.a('TLS', attr.href('#tlsrpt/reports'))), dom.br(), dom.h2('Operations'), dom.div(dom.a('MTA-STS policies', attr.href('#mtasts'))), dom.div(dom.a('DMARC evaluations', attr.href('#dmarc/evaluations'))), dom.div(dom.a('TLS connection results', attr.href('#tlsrpt/results'))), dom.div(dom.a('DNSBL', attr.href('#dnsbl'))), dom.div(style({ marginTop: '.5ex' }), dom.form(async function submit(e) {
-		e.preventDefault();
-		e.stopPropagation();
-		dom._kids(cidElem);
-		const cid = await check(recvIDFieldset, client.LookupCid(recvID.value));
-		dom._kids(cidElem, cid);
-	}, recvIDFieldset = dom.fieldset(dom.label('Received ID', attr.title('The ID in the Received header that was added during incoming delivery.')), ' ', recvID = dom.input(attr.required('')), ' ', dom.submitbutton('Lookup cid', attr.title('Logging about an incoming message includes an attribute "cid", a counter identifying the transaction related to delivery of the message. The ID in the received header is an encrypted cid, which this form decrypts, after which you can look it up in the logging.')), ' ', cidElem = dom.span()))), 
+	return dom.div(crumbs('Mox Admin'), checkUpdatesEnabled ? [] : dom.p(box(yellow, 'Warning: Checking for updates has not been enabled in mox.conf (CheckUpdates: true).', dom.br(), 'Make sure you stay up to date through another mechanism!', dom.br(), 'You have a responsibility to keep the internet-connected software you run up to date and secure!', dom.br(), 'See ', link('https://updates.xmox.nl/changelog'))), adminName ? dom.p('Logged in as admin user ', dom.b(adminName), ', with role ', adminUser.Role, '.') : [], dom.p(dom.a('Accounts', attr.href('#accounts')), dom.br(), domainAdmin ? [] : [
+		dom.a('Queue', attr.href('#queue')), ' (' + queueSize + ')', dom.br(),
+		dom.a('Webhook queue', attr.href('#webhookqueue')), ' (' + hooksQueueSize + ')', dom.br(),
+	]), dom.h2('Domains'), (domains || []).length === 0 ? box(red, 'No domains') :
+		dom.ul((domains || []).map(d => dom.li(dom.a(attr.href('#domains/' + domainName(d.Domain)), domainString(d.Domain)), d.Disabled ? ' (disabled)' : []))), !fullAdmin ? [] : [
+		dom.br(),
+		dom.h2('Add domain'),
+		dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			await check(fieldset, client.DomainAdd(disabled.checked, domain.value, account.value, localpart.value));
+			window.location.hash = '#domains/' + domain.value;
+		}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), dom.span('Domain', attr.title('Domain for incoming/outgoing email to add to mox. Can also be a subdomain of a domain already configured.')), dom.br(), domain = dom.input(attr.required(''))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Postmaster/reporting account', attr.title('Account that is considered the owner of this domain. If the account does not yet exist, it will be created and a a localpart is required for the initial email address.')), dom.br(), account = dom.input(attr.required(''), attr.list('accountList')), dom.datalist(attr.id('accountList'), (accounts || []).map(a => dom.option(attr.value(a), a + (accountsDisabled?.includes(a) ? ' (disabled)' : ''))))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Localpart (if new account)', attr.title('Must be set if and only if account does not yet exist. A localpart is the part before the "@"-sign of an email address. An account requires an email address, so creating a new account for a domain requires a localpart to form an initial email address.')), dom.br(), localpart = dom.input()), ' ', dom.label(disabled = dom.input(attr.type('checkbox')), ' Disabled', attr.title('Disabled domains do fetch new certificates with ACME and do not accept incoming or outgoing messages involving the domain. Accounts and addresses referencing a disabled domain can be created. USeful during/before migrations.')), ' ', dom.submitbutton('Add domain', attr.title('Domain will be added and the config reloaded. Add the required DNS records after adding the domain.')))),
+	], domainAdmin ? [] : [
+		dom.br(),
+		dom.h2('Reports'),
+		dom.div(dom.a('DMARC', attr.href('#dmarc/reports'))),
+		dom.div(dom.a('TLS', attr.href('#tlsrpt/reports'))),
+		dom.br(),
+		dom.h2('Operations'),
+		dom.div(dom.a('MTA-STS policies', attr.href('#mtasts'))),
+		dom.div(dom.a('DMARC evaluations', attr.href('#dmarc/evaluations'))),
+		dom.div(dom.a('TLS connection results', attr.href('#tlsrpt/results'))),
+		dom.div(dom.a('DNSBL', attr.href('#dnsbl'))),
+		dom.div(dom.a('Sender reputation', attr.href('#senderreputation'))),
+		dom.div(dom.a('SPF analysis', attr.href('#spf'))),
+		dom.div(dom.a('DANE TLSA records', attr.href('#dane'))),
+		dom.div(dom.a('Audit log', attr.href('#auditlog'))),
+		dom.div(dom.a('Signup', attr.href('#signup'))),
+		dom.div(style({ marginTop: '.5ex' }), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			dom._kids(cidElem);
+			const cid = await check(recvIDFieldset, client.LookupCid(recvID.value));
+			dom._kids(cidElem, cid);
+		}, recvIDFieldset = dom.fieldset(dom.label('Received ID', attr.title('The ID in the Received header that was added during incoming delivery.')), ' ', recvID = dom.input(attr.required('')), ' ', dom.submitbutton('Lookup cid', attr.title('Logging about an incoming message includes an attribute "cid", a counter identifying the transaction related to delivery of the message. The ID in the received header is an encrypted cid, which this form decrypts, after which you can look it up in the logging.')), ' ', cidElem = dom.span()))),
+	], 
 	// todo: routing, globally, per domain and per account
-	dom.br(), dom.h2('Configuration'), dom.div(dom.a('Routes', attr.href('#routes'))), dom.div(dom.a('Webserver', attr.href('#webserver'))), dom.div(dom.a('Files', attr.href('#config'))), dom.div(dom.a('Log levels', attr.href('#loglevels'))), footer());
+	!fullAdmin ? [] : [
+		dom.br(),
+		dom.h2('Configuration'),
+		dom.div(dom.a('Routes', attr.href('#routes'))),
+		dom.div(dom.a('Webserver', attr.href('#webserver'))),
+		dom.div(dom.a('Files', attr.href('#config'))),
+		dom.div(dom.a('Log levels', attr.href('#loglevels'))),
+		dom.div(dom.a('Admin users', attr.href('#adminusers'))),
+		adminName ? [] : dom.div(dom.a('Two-factor authentication', attr.href('#twofactor'))),
+	], footer());
 };
 const globalRoutes = async () => {
 	const [transports, config] = await Promise.all([
@@ -2011,6 +3120,90 @@ const config = async () => {
 	const [staticPath, dynamicPath, staticText, dynamicText] = await client.ConfigFiles();
 	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Config'), dom.h2(staticPath), dom.pre(dom._class('literal'), staticText), dom.h2(dynamicPath), dom.pre(dom._class('literal'), dynamicText));
 };
+const twofactor = async () => {
+	const [enabled, passkeys] = await Promise.all([
+		client.AdminTOTPEnabled(),
+		client.AdminPasskeys(),
+	]);
+	const nowSecs = new Date().getTime() / 1000;
+	let setupBox;
+	let fieldset;
+	let code;
+	let passkeyFieldset;
+	let passkeyName;
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Two-factor authentication'), dom.p('With two-factor authentication, admin logins require a code from an authenticator app in addition to the admin password. Removing the file adminpasswd.totp in the config directory disables two-factor authentication.'), enabled ?
+		dom.p('Enabled. ', dom.clickbutton('Disable', async function click(e) {
+			if (!window.confirm('Are you sure? Admin logins will only require the password.')) {
+				return;
+			}
+			await check(e.target, client.AdminTOTPRemove());
+			window.location.reload();
+		})) :
+		setupBox = dom.div(dom.p('Not enabled.'), dom.clickbutton('Set up two-factor authentication', async function click(e) {
+			const setup = await check(e.target, client.AdminTOTPSetupStart());
+			dom._kids(setupBox, dom.p('Scan the QR code with your authenticator app, or enter the secret manually. Then enter the code shown by the app to confirm.'), dom.img(attr.src(setup.QRCodePNG), attr.title(setup.URL)), dom.p('Secret: ', dom.span(dom._class('literal'), setup.Secret)), dom.form(fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Code', dom.br(), code = dom.input(attr.required(''), attr.autocomplete('one-time-code'))), ' ', dom.submitbutton('Confirm')), async function submit(e) {
+				e.stopPropagation();
+				e.preventDefault();
+				await check(fieldset, client.AdminTOTPSetupConfirm(code.value));
+				window.alert('Two-factor authentication is now enabled.');
+				window.location.reload();
+			}));
+		})), dom.br(), dom.h2('Passkeys'), dom.p('A passkey, stored in your browser, password manager, phone or security key, can be used to login without password. With two-factor authentication enabled, a passkey can also be used as second factor after the password.'), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Created'), dom.th('Last used'), dom.th('Action'))), dom.tbody(passkeys?.length ? [] : dom.tr(dom.td(attr.colspan('4'), 'No passkeys.')), (passkeys || []).map(pk => dom.tr(dom.td(pk.Name), dom.td(age(pk.Created, false, nowSecs)), dom.td(pk.LastUsed.getTime() > 0 ? age(pk.LastUsed, false, nowSecs) : 'never'), dom.td(dom.clickbutton('Remove', async function click(e) {
+		if (!window.confirm('Are you sure? The passkey can no longer be used to login.')) {
+			return;
+		}
+		await check(e.target, client.AdminPasskeyRemove(pk.CredentialID));
+		window.location.reload(); // todo: only refresh the list
+	})))))), dom.br(), window.PublicKeyCredential ?
+		dom.form(passkeyFieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Name', dom.br(), passkeyName = dom.input(attr.required(''), attr.placeholder('Laptop'))), ' ', dom.submitbutton('Add passkey')), async function submit(e) {
+			e.stopPropagation();
+			e.preventDefault();
+			await check(passkeyFieldset, (async () => {
+				const opts = await client.AdminPasskeyRegisterStart();
+				const [clientDataJSON, attestationObject] = await passkeyCreate(opts);
+				await client.AdminPasskeyRegister(passkeyName.value, clientDataJSON, attestationObject);
+			})());
+			window.location.reload(); // todo: only refresh the list
+		}) :
+		dom.p('Your browser does not support passkeys.'));
+};
+const adminUsers = async () => {
+	const users = await client.AdminUsers();
+	const roles = ['admin', 'domainadmin', 'auditor'];
+	const parseDomains = (s) => s.split(',').map(s => s.trim()).filter(s => !!s);
+	let fieldset;
+	let name;
+	let role;
+	let domains;
+	let password;
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Admin users'), dom.p('Admin users login with their username and password. Role "admin" has full access. Role "domainadmin" can manage its domains, with their accounts, addresses and aliases. Role "auditor" has read-only access. Logins with the admin password, without username, always have full access.'), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Role'), dom.th('Domains', attr.title('Comma-separated, for role domainadmin.')), dom.th('Action'))), dom.tbody(Object.keys(users).length ? [] : dom.tr(dom.td(attr.colspan('4'), 'No admin users.')), Object.keys(users).sort().map(n => {
+		const au = users[n];
+		let userRole;
+		let userDomains;
+		return dom.tr(dom.td(n), dom.td(userRole = dom.select(roles.map(r => dom.option(r, r === au.Role ? attr.selected('') : [])))), dom.td(userDomains = dom.input(attr.value((au.Domains || []).join(', ')))), dom.td(dom.clickbutton('Save', async function click(e) {
+			await check(e.target, client.AdminUserUpdate(n, userRole.value, parseDomains(userDomains.value)));
+			window.location.reload(); // todo: only refresh the list
+		}), ' ', dom.clickbutton('Set password', async function click(e) {
+			const pw = window.prompt('New password for admin user ' + n);
+			if (!pw) {
+				return;
+			}
+			await check(e.target, client.AdminUserSetPassword(n, pw));
+			window.alert('Password has been changed.');
+		}), ' ', dom.clickbutton('Remove', async function click(e) {
+			if (!window.confirm('Are you sure? Sessions of the admin user will no longer be valid.')) {
+				return;
+			}
+			await check(e.target, client.AdminUserRemove(n));
+			window.location.reload(); // todo: only refresh the list
+		})));
+	}))), dom.br(), dom.h2('Add admin user'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(fieldset, client.AdminUserAdd(name.value, role.value, parseDomains(domains.value), password.value));
+		window.location.reload(); // todo: only refresh the list
+	}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Name', dom.br(), name = dom.input(attr.required(''))), ' ', dom.label(style({ display: 'inline-block' }), 'Role', dom.br(), role = dom.select(roles.map(r => dom.option(r)))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Domains', attr.title('Comma-separated, for role domainadmin.')), dom.br(), domains = dom.input()), ' ', dom.label(style({ display: 'inline-block' }), 'Password', dom.br(), password = dom.input(attr.type('password'), attr.autocomplete('new-password'), attr.required(''))), ' ', dom.submitbutton('Add admin user'))));
+};
 const loglevels = async () => {
 	const loglevels = await client.LogLevels();
 	const levels = ['error', 'info', 'warn', 'debug', 'trace', 'traceauth', 'tracedata'];
@@ -2064,8 +3257,13 @@ const accounts = async () => {
 	let domain;
 	let account;
 	let accountModified = false;
+	let importFieldset;
+	let importText;
+	let importRequireChange;
+	let importDryRun = false;
+	let importResults;
 	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Accounts'), dom.h2('Accounts'), (accounts || []).length === 0 ? dom.p('No accounts') :
-		dom.ul((accounts || []).map(s => dom.li(dom.a(attr.href('#accounts/l/' + s), s), accountsDisabled?.includes(s) ? ' (disabled)' : ''))), dom.br(), dom.h2('Add account'), dom.form(async function submit(e) {
+		dom.ul((accounts || []).map(s => dom.li(dom.a(attr.href('#accounts/l/' + s), s), accountsDisabled?.includes(s) ? ' (disabled)' : ''))), dom.p('See ', dom.a(attr.href('#accounts/storage'), 'storage usage'), ' for accounts using most storage.'), dom.br(), dom.h2('Add account'), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(fieldset, client.AccountAdd(account.value, localpart.value + '@' + domain.value));
@@ -2076,7 +3274,17 @@ const accounts = async () => {
 		}
 	})), '@', dom.label(style({ display: 'inline-block' }), dom.span('Domain', attr.title('The domain of the email address, after the "@".')), dom.br(), domain = dom.select(attr.required(''), (domains || []).map(d => dom.option(domainName(d.Domain))))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Account name', attr.title('An account has a password, and email address(es) (possibly at different domains). Its messages and the message index database are are stored in the file system in a directory with the name of the account. An account name is not an email address. Use a name like a unix user name, or the localpart (the part before the "@") of the initial address.')), dom.br(), account = dom.input(attr.required(''), function change() {
 		accountModified = true;
-	})), ' ', dom.submitbutton('Add account', attr.title('The account will be added and the config reloaded.')))), dom.br(), dom.h2('Recent login attempts', attr.title('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored per account to prevent unlimited growth of the database.')), renderLoginAttempts(true, loginAttempts || []), dom.br(), loginAttempts && loginAttempts.length >= 10 ? dom.p('See ', dom.a(attr.href('#accounts/loginattempts'), 'all login attempts'), '.') : []);
+	})), ' ', dom.submitbutton('Add account', attr.title('The account will be added and the config reloaded.')))), dom.br(), dom.h2('Import accounts'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const results = await check(importFieldset, client.AccountsImport(importText.value, importDryRun, importRequireChange.checked));
+		const failed = (results || []).filter(r => r.Error).length;
+		dom._kids(importResults, failed > 0 ? box(red, '' + failed + ' of ' + (results || []).length + ' accounts cannot be added, no accounts were added.') :
+			box(green, importDryRun ? 'All ' + (results || []).length + ' accounts can be added.' : 'Accounts added. Initial passwords are only shown once, distribute them to the account holders.'), dom.table(dom.thead(dom.tr(dom.th('Account'), dom.th('Address'), dom.th('Password'), dom.th('Error'))), dom.tbody((results || []).map(r => dom.tr(dom.td(r.Name), dom.td(prewrap(r.Address)), dom.td(prewrap(r.Password)), dom.td(r.Error))))));
+		if (failed === 0 && !importDryRun) {
+			importText.value = '';
+		}
+	}, importFieldset = dom.fieldset(dom.p('Add multiple accounts at once. Either all accounts are added, or none. Each line has comma-separated fields: account name, address, full name, additional addresses (space-separated), quota message size in bytes, password. Fields after the address are optional. Initial passwords are generated for accounts without password. A JSON array of objects with fields Name, Address, FullName, Addresses, QuotaMessageSize and Password is also accepted.'), importText = dom.textarea(attr.required(''), attr.rows('6'), style({ width: '60em' }), attr.placeholder('name,address,fullname,addresses,quota,password\njohn,john@example.org,John Doe,jd@example.org,,')), dom.br(), dom.label(importRequireChange = dom.input(attr.type('checkbox')), ' Require password change', attr.title('Accounts must set a new password through the account web interface before logging in with other protocols.')), ' ', dom.submitbutton('Check', attr.title('Only check whether the accounts can be added.'), function click() { importDryRun = true; }), ' ', dom.submitbutton('Import accounts', attr.title('The accounts will be added and the config reloaded.'), function click() { importDryRun = false; }))), importResults = dom.div(), dom.br(), dom.h2('Recent login attempts', attr.title('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored per account to prevent unlimited growth of the database.')), renderLoginAttempts(true, loginAttempts || []), dom.br(), loginAttempts && loginAttempts.length >= 10 ? dom.p('See ', dom.a(attr.href('#accounts/loginattempts'), 'all login attempts'), '.') : []);
 };
 const loginattempts = async () => {
 	const loginAttempts = await client.LoginAttempts("", 0);
@@ -2086,6 +3294,100 @@ const accountloginattempts = async (accountName) => {
 	const loginAttempts = await client.LoginAttempts(accountName, 0);
 	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Accounts', '#accounts'), ['(admin)', '-'].includes(accountName) ? accountName : crumblink(accountName, '#accounts/l/' + accountName), 'Login attempts'), dom.h2('Login attempts'), dom.p('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored per account to prevent unlimited growth of the database.'), renderLoginAttempts(false, loginAttempts || []));
 };
+const accountjunkverdicts = async (accountName, junkOnly) => {
+	const verdicts = await client.AccountJunkVerdicts(accountName, junkOnly, 100) || [];
+	const nowSecs = new Date().getTime() / 1000;
+	const popupVerdict = (v) => {
+		const words = (l) => l.map(w => w.Word + ' (' + w.Score.toFixed(3) + ')').join(', ') || '-';
+		popup(style({ maxWidth: '60em' }), dom.h1('Junk analysis'), dom.table(dom.tr(dom.td('Subject'), dom.td(v.Subject)), dom.tr(dom.td('From'), dom.td(v.From)), dom.tr(dom.td('Verdict'), dom.td(v.Verdict.Accept ? 'Accepted' : 'Rejected', ', reason ', v.Verdict.Reason)), dom.tr(dom.td('Mailbox'), dom.td(v.Verdict.Mailbox || '(destination)')), v.Verdict.Classified ? [
+			dom.tr(dom.td('Score'), dom.td(v.Verdict.Score.toFixed(3) + ', threshold ' + v.Verdict.Threshold.toFixed(3) + (v.Verdict.Significant ? '' : ', not significant'))),
+			dom.tr(dom.td('Spam words'), dom.td(words(v.Verdict.SpamWords || []))),
+			dom.tr(dom.td('Ham words'), dom.td(words(v.Verdict.HamWords || []))),
+		] : dom.tr(dom.td('Score'), dom.td('Not classified by junk filter'))), dom.h2('Details'), (v.Verdict.Details || []).length === 0 ? dom.p('No details.') : dom.ul((v.Verdict.Details || []).map(s => dom.li(s))));
+	};
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Accounts', '#accounts'), crumblink(accountName, '#accounts/l/' + accountName), 'Junk analysis verdicts'), dom.h2('Junk analysis verdicts'), dom.p('Verdicts of the junk analysis during delivery of the most recent messages that are still in the account. Only messages delivered over SMTP have a verdict.'), dom.p(junkOnly ? dom.a(attr.href('#accounts/l/' + accountName + '/junkverdicts'), 'Show all messages') : dom.a(attr.href('#accounts/l/' + accountName + '/junkverdicts/junk'), 'Show only rejected and junk messages')), dom.table(dom.thead(dom.tr(dom.th('Received'), dom.th('Mailbox'), dom.th('From'), dom.th('Subject'), dom.th('Flags', attr.title('Current junk flags of the message, e.g. after being marked by the user.')), dom.th('Verdict'), dom.th('Score'), dom.th('Action'))), dom.tbody(verdicts.length === 0 ? dom.tr(dom.td(attr.colspan('8'), 'No messages with verdicts.')) : [], verdicts.map(v => dom.tr(dom.td(age(v.Received, false, nowSecs)), dom.td(v.Mailbox), dom.td(v.From), dom.td(v.Subject), dom.td(v.Junk ? 'junk' : (v.Notjunk ? 'notjunk' : '')), dom.td(v.Verdict.Accept ? v.Verdict.Reason : box(red, v.Verdict.Reason), v.Verdict.Mailbox ? ' → ' + v.Verdict.Mailbox : ''), dom.td(v.Verdict.Classified ? v.Verdict.Score.toFixed(3) : '-'), dom.td(dom.clickbutton('Details', function click() { popupVerdict(v); })))))));
+};
+const accountsStorage = async () => {
+	const limit = 100;
+	const usage = await client.StorageUsage(limit);
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Accounts', '#accounts'), 'Storage usage'), dom.p('Accounts using most storage, at most ' + limit + '. The quota is the maximum total message size, configured per account, per domain or globally. Accounts can be over their soft limit during a grace period, after which new messages are rejected.'), dom.table(dom.thead(dom.tr(dom.th('Account'), dom.th('Usage'), dom.th('Quota'), dom.th('% of quota'), dom.th('Soft limit'), dom.th('Grace period end'))), dom.tbody((usage || []).length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No accounts.')), (usage || []).map(qs => dom.tr(dom.td(dom.a(attr.href('#accounts/l/' + qs.Account), qs.Account)), dom.td(style({ textAlign: 'right' }), formatSize(qs.MessageSize)), dom.td(style({ textAlign: 'right' }), qs.Quota ? formatSize(qs.Quota) : '-'), dom.td(style({ textAlign: 'right' }), qs.Quota ? (qs.Percent >= 90 ? box(red, qs.Percent + '%') : qs.Percent >= 80 ? box(yellow, qs.Percent + '%') : qs.Percent + '%') : '-'), dom.td(style({ textAlign: 'right' }), qs.SoftLimit ? formatSize(qs.SoftLimit) : '-'), dom.td(qs.GraceEnd.getTime() > 0 ? box(qs.GraceEnd.getTime() < new Date().getTime() ? red : yellow, qs.GraceEnd.toISOString()) : '-'))))));
+};
+const auditlog = async () => {
+	const limit = 1000;
+	const events = await client.AuditList({ Kind: '', Account: '', Actor: '', Start: null, End: null }, limit);
+	const kinds = ['', 'login', 'password', 'settings', 'config', 'account', 'export', 'queue'];
+	let fieldset;
+	let kind;
+	let account;
+	let actor;
+	let eventsElem;
+	const render = (l) => {
+		const nowSecs = new Date().getTime() / 1000;
+		return dom.table(dom.thead(dom.tr(dom.th('Time'), dom.th('Kind'), dom.th('Action'), dom.th('Result'), dom.th('Account'), dom.th('Actor'), dom.th('Protocol'), dom.th('Remote IP'), dom.th('Details'))), dom.tbody(l.length ? [] : dom.tr(dom.td(attr.colspan('9'), 'No events.')), l.map(e => dom.tr(dom.td(age(e.Time, false, nowSecs)), dom.td(e.Kind), dom.td(e.Action), dom.td(e.Result === 'ok' ? e.Result : box(red, e.Result)), dom.td(e.Account), dom.td(e.Actor), dom.td(e.Protocol), dom.td(e.RemoteIP), dom.td(e.Details)))));
+	};
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Audit log'), dom.p('The audit log records security-relevant events: logins, password and settings changes, configuration changes, account additions and removals, exports and queue changes. Parameters of changes are not recorded. Events are kept for the retention period configured in mox.conf, by default 365 days. The audit log can be exported with "mox auditlog export".'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const filter = { Kind: kind.value, Account: account.value, Actor: actor.value, Start: null, End: null };
+		const l = await check(fieldset, client.AuditList(filter, limit));
+		dom._kids(eventsElem, render(l || []));
+	}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Kind', dom.br(), kind = dom.select(kinds.map(k => dom.option(k, attr.value(k))))), ' ', dom.label(style({ display: 'inline-block' }), 'Account', dom.br(), account = dom.input()), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Actor', attr.title('Account name, "(admin)" for logins with the admin password, "(admin:<user>)" for admin users, or "(ctl)" for commands on the command-line.')), dom.br(), actor = dom.input()), ' ', dom.submitbutton('Search'))), dom.br(), dom.p('At most ' + limit + ' most recent events are shown.'), eventsElem = dom.div(render(events || [])));
+};
+const signup = async () => {
+	const [requests, invites, domains] = await Promise.all([
+		client.SignupRequests(),
+		client.SignupInvites(),
+		client.Domains(),
+	]);
+	let inviteFieldset;
+	let inviteDomain;
+	let inviteMaxUses;
+	let inviteDays;
+	let inviteNote;
+	let inviteLink;
+	const signupDomains = (domains || []).filter(d => d.Signup);
+	const pending = (requests || []).filter(r => r.State === api.SignupState.SignupPending);
+	const other = (requests || []).filter(r => r.State !== api.SignupState.SignupPending);
+	const nowSecs = new Date().getTime() / 1000;
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Signup'), dom.p('Accounts can be requested through the self-service signup page for domains with signup enabled. The contact email address of a request is verified before an account is created. Signups require an invitation, unless the domain has open signups. Abuse protections, such as limits per IP and a captcha, are configured in mox.conf.'), dom.h2('Pending requests'), dom.table(dom.thead(dom.tr(dom.th('Created'), dom.th('Address'), dom.th('Full name'), dom.th('Contact email'), dom.th('Invite'), dom.th('Remote IP'), dom.th('Action'))), dom.tbody(pending.length ? [] : dom.tr(dom.td(attr.colspan('7'), 'No pending requests.')), pending.map(r => {
+		let fieldset;
+		return dom.tr(dom.td(age(r.Created, false, nowSecs)), dom.td(r.Address), dom.td(r.FullName), dom.td(r.ContactEmail), dom.td(r.InviteID ? '' + r.InviteID : ''), dom.td(r.RemoteIP), dom.td(fieldset = dom.fieldset(dom.clickbutton('Approve', attr.title('Create the account and notify the contact email address.'), async function click() {
+			await check(fieldset, client.SignupApprove(r.ID));
+			window.location.reload(); // todo: only refresh the list
+		}), ' ', dom.clickbutton('Reject', async function click() {
+			const reason = window.prompt('Reason for rejection, optional, included in the notification to the contact email address.');
+			if (reason === null) {
+				return;
+			}
+			await check(fieldset, client.SignupReject(r.ID, reason));
+			window.location.reload(); // todo: only refresh the list
+		}))));
+	}))), dom.br(), dom.h2('Invitations'), dom.table(dom.thead(dom.tr(dom.th('ID'), dom.th('Created'), dom.th('Domain'), dom.th('Expires'), dom.th('Uses'), dom.th('Note'), dom.th('Created by'), dom.th('Action'))), dom.tbody((invites || []).length ? [] : dom.tr(dom.td(attr.colspan('8'), 'No invitations.')), (invites || []).map(si => dom.tr(dom.td('' + si.ID), dom.td(age(si.Created, false, nowSecs)), dom.td(si.Domain), dom.td(age(si.Expires, true, nowSecs)), dom.td('' + si.Uses + '/' + si.MaxUses), dom.td(si.Note), dom.td(si.CreatedBy), dom.td(dom.clickbutton('Remove', async function click(e) {
+		if (!window.confirm('Are you sure? The invitation can no longer be used.')) {
+			return;
+		}
+		await check(e.target, client.SignupInviteRemove(si.ID));
+		window.location.reload(); // todo: only refresh the list
+	})))))), dom.br(), dom.h2('Add invitation'), signupDomains.length === 0 ? dom.p('Enable signup for a domain below before adding invitations.') : dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const [token, signupPath] = await check(inviteFieldset, client.SignupInviteAdd(inviteDomain.value, parseInt(inviteMaxUses.value), parseInt(inviteDays.value), inviteNote.value));
+		const url = new URL(signupPath + '?invite=' + encodeURIComponent(token), window.location.href).href;
+		dom._kids(inviteLink, box(green, 'Invitation added. The link is only shown once, send it to the invitee: ', prewrap(url), ' ', dom.clickbutton('Copy', function click() { navigator.clipboard.writeText(url); })));
+		inviteNote.value = '';
+	}, inviteFieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Domain', dom.br(), inviteDomain = dom.select(signupDomains.map(d => dom.option(domainName(d.Domain), attr.value(d.Domain.Name))))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Max uses', attr.title('Number of accounts that can be created with the invitation.')), dom.br(), inviteMaxUses = dom.input(attr.type('number'), attr.required(''), attr.min('1'), attr.value('1'), style({ width: '6em' }))), ' ', dom.label(style({ display: 'inline-block' }), 'Valid for days', dom.br(), inviteDays = dom.input(attr.type('number'), attr.required(''), attr.min('1'), attr.value('7'), style({ width: '6em' }))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Note', attr.title('For admins, e.g. who the invitation is for.')), dom.br(), inviteNote = dom.input()), ' ', dom.submitbutton('Add invitation'))), inviteLink = dom.div(), dom.br(), dom.h2('Domains'), dom.p('Self-service signup is only possible for domains with signup enabled.'), dom.table(dom.thead(dom.tr(dom.th('Domain'), dom.th('Enabled'), dom.th('Open', attr.title('Allow signups without invitation.')), dom.th('Require approval', attr.title('An admin must approve signups after the contact email address is verified.')), dom.th('Quota', attr.title('Maximum total message size in bytes for new accounts. Zero for the default quota of the domain.')), dom.th('Action'))), dom.tbody((domains || []).length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No domains.')), (domains || []).map(d => {
+		let fieldset;
+		let enabled;
+		let open;
+		let requireApproval;
+		let quota;
+		return dom.tr(dom.td(dom.a(attr.href('#domains/' + domainName(d.Domain)), domainString(d.Domain))), dom.td(enabled = dom.input(attr.type('checkbox'), d.Signup ? attr.checked('') : [])), dom.td(open = dom.input(attr.type('checkbox'), d.Signup?.Open ? attr.checked('') : [])), dom.td(requireApproval = dom.input(attr.type('checkbox'), d.Signup?.RequireApproval ? attr.checked('') : [])), dom.td(quota = dom.input(attr.type('number'), attr.min('0'), attr.value('' + (d.Signup?.QuotaMessageSize || 0)), style({ width: '10em' }))), dom.td(fieldset = dom.fieldset(dom.clickbutton('Save', async function click() {
+			const ds = enabled.checked ? { Open: open.checked, RequireApproval: requireApproval.checked, QuotaMessageSize: parseInt(quota.value) || 0 } : null;
+			await check(fieldset, client.DomainSignupSave(d.Domain.Name, ds));
+			window.location.reload(); // todo: only refresh the list
+		}))));
+	}))), dom.br(), dom.h2('Other requests'), dom.p('Unverified requests are removed after 24 hours.'), dom.table(dom.thead(dom.tr(dom.th('Created'), dom.th('State'), dom.th('Address'), dom.th('Contact email'), dom.th('Remote IP'), dom.th('Decided'), dom.th('Decided by'), dom.th('Reject reason'))), dom.tbody(other.length ? [] : dom.tr(dom.td(attr.colspan('8'), 'No requests.')), other.map(r => dom.tr(dom.td(age(r.Created, false, nowSecs)), dom.td(r.State), dom.td(r.Address), dom.td(r.ContactEmail), dom.td(r.RemoteIP), dom.td(r.Decided.getTime() > 0 ? age(r.Decided, false, nowSecs) : ''), dom.td(r.DecidedBy), dom.td(r.RejectReason))))));
+};
 const renderLoginAttempts = (accountLinks, loginAttempts) => {
 	// todo: pagination and search
 	const nowSecs = new Date().getTime() / 1000;
@@ -2167,14 +3469,93 @@ const RoutesEditor = (kind, transports, routes, save) => {
 	};
 	return render();
 };
+const OutgoingFooterEditor = (kind, footer, save) => {
+	let fieldset;
+	let text;
+	let html;
+	return dom.div(dom.h2('Outgoing footer', attr.title('Footer, e.g. a disclaimer, added to the text of outgoing messages during submission, before DKIM signing. Added to text/plain and text/html parts of the message text, not to attachments. Signed or encrypted messages are not changed. A footer configured for an account takes precedence over a footer for the domain of the message From address.')), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const lines = text.value.split('\n').map(s => s.trimEnd());
+		while (lines.length > 0 && !lines[lines.length - 1]) {
+			lines.pop();
+		}
+		const nfooter = lines.length === 0 ? null : { Text: lines, HTML: html.value.split('\n').map(s => s.trim()).filter(s => s).join(' ') };
+		await check(fieldset, save(nfooter));
+	}, fieldset = dom.fieldset(dom.label(style({ display: 'block', marginBottom: '1ex' }), attr.title('Lines of text added to text/plain parts. Leave empty to remove the ' + kind + ' footer.'), dom.div('Text'), text = dom.textarea(new String((footer?.Text || []).join('\n')), attr.rows('4'), style({ width: '40em' }))), dom.label(style({ display: 'block', marginBottom: '1ex' }), attr.title('HTML added to text/html parts, before the closing body tag. If empty, the HTML is generated from the text.'), dom.div('HTML (optional)'), html = dom.textarea(new String(footer?.HTML || ''), attr.rows('3'), style({ width: '40em' }))), dom.div(dom.submitbutton('Save')))));
+};
+const SpamActionsEditor = (kind, sa, save) => {
+	let fieldset;
+	let tagThreshold;
+	let junkThreshold;
+	let highAction;
+	return dom.div(dom.h2('Spam actions', attr.title('Actions for incoming messages based on the spaminess score of the junk filter: tag messages with a low score, deliver messages with a medium score to the Junk mailbox, and reject or quarantine messages with a score above the junk filter threshold. ' + (kind === 'domain' ? 'Defaults for accounts with this domain as their default domain, used for fields not set for the account.' : 'Fields not set for the account are taken from the default domain of the account.'))), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const nsa = {
+			TagThreshold: parseFloat(tagThreshold.value) || 0,
+			JunkThreshold: parseFloat(junkThreshold.value) || 0,
+			HighAction: highAction.value,
+		};
+		await check(fieldset, save(kind === 'domain' && !nsa.TagThreshold && !nsa.JunkThreshold && !nsa.HighAction ? null : nsa));
+	}, fieldset = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.label('Tag threshold', attr.title('Spaminess score between 0 and 1 above which accepted messages get X-Mox-Spam-Flag and X-Mox-Spam-Score headers. E.g. 0.5. Empty or 0 for no tagging.'), dom.div(tagThreshold = dom.input(attr.value(sa?.TagThreshold ? '' + sa.TagThreshold : '')))), dom.label('Junk threshold', attr.title('Spaminess score between 0 and 1 above which messages are accepted but delivered to the Junk mailbox. Should be below the junk filter threshold. E.g. 0.8. Empty or 0 to not deliver to Junk based on score.'), dom.div(junkThreshold = dom.input(attr.value(sa?.JunkThreshold ? '' + sa.JunkThreshold : '')))), dom.label('High action', attr.title('Action for messages with a spaminess score above the junk filter threshold. Reject rejects the message during the SMTP transaction. Quarantine accepts the message, but only stores it in the rejects mailbox of the account.'), dom.div(highAction = dom.select(dom.option('Default', attr.value('')), dom.option('Reject', attr.value('reject'), sa?.HighAction === 'reject' ? attr.selected('') : []), dom.option('Quarantine', attr.value('quarantine'), sa?.HighAction === 'quarantine' ? attr.selected('') : [])))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save')))))));
+};
+const SpamtrapsEditor = (localparts, save) => {
+	let fieldset;
+	let text;
+	return dom.div(dom.h2('Spamtraps', attr.title('Messages sent to spamtrap addresses are accepted but never delivered. The server-wide reputation of the sending IP and validated message From domain is lowered, and the message is trained as spam in the shared junk filter of the domain, if configured. Spamtrap addresses should never be given out, only be found by spammers, e.g. in hidden links on web pages. They must not be configured as address of an account or alias.')), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(fieldset, save(text.value.split('\n').map(s => s.trim()).filter(s => s)));
+	}, fieldset = dom.fieldset(dom.label(style({ display: 'block', marginBottom: '1ex' }), attr.title('Localparts of spamtrap addresses in this domain, the part before the "@"-sign, one per line.'), dom.div('Localparts'), text = dom.textarea(new String(localparts.join('\n')), attr.rows('4'), style({ width: '20em' }))), dom.div(dom.submitbutton('Save')))));
+};
+const MessageTemplatesEditor = (templates, save) => {
+	let fieldset;
+	let templatesElem;
+	let views = [];
+	const templateView = (t) => {
+		let name;
+		let subject;
+		let text;
+		const root = dom.div(style({ marginBottom: '1ex' }), dom.div(dom.label('Name ', name = dom.input(attr.value(t.Name), attr.required(''))), ' ', dom.label('Subject ', subject = dom.input(attr.value(t.Subject), style({ width: '20em' }))), ' ', dom.clickbutton('Remove', function click() {
+			views = views.filter(v => v !== tv);
+			root.remove();
+		})), text = dom.textarea(new String((t.Text || []).join('\n')), attr.rows('4'), style({ width: '40em' })));
+		const tv = { root: root, name: name, subject: subject, text: text };
+		return tv;
+	};
+	views = templates.map(t => templateView(t));
+	return dom.div(dom.h2('Message templates', attr.title('Templates for composing messages in the webmail, e.g. canned responses, available to all accounts with an address in this domain. The subject and text can contain placeholders that are replaced when the template is used: {{recipient.name}}, {{recipient.firstname}}, {{recipient.email}}, {{sender.name}}, {{sender.email}} and {{date}}.')), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const l = views.map(v => {
+			const lines = v.text.value.split('\n').map(s => s.trimEnd());
+			while (lines.length > 0 && !lines[lines.length - 1]) {
+				lines.pop();
+			}
+			return { Name: v.name.value.trim(), Subject: v.subject.value, Text: lines };
+		});
+		await check(fieldset, save(l));
+	}, fieldset = dom.fieldset(templatesElem = dom.div(views.map(v => v.root)), dom.div(style({ marginBottom: '1ex' }), dom.clickbutton('Add template', function click() {
+		const v = templateView({ Name: '', Subject: '', Text: [] });
+		views.push(v);
+		templatesElem.appendChild(v.root);
+		v.name.focus();
+	})), dom.div(dom.submitbutton('Save')))));
+};
 const account = async (name) => {
-	const [[config, diskUsage], domains, transports, tlspubkeys, loginAttempts] = await Promise.all([
+	const [[config, diskUsage], domains, transports, tlspubkeys, loginAttempts, twoFactorEnabled, passkeys, sendSuspension, storage] = await Promise.all([
 		client.Account(name),
 		client.Domains(),
 		client.Transports(),
 		client.TLSPublicKeys(name),
 		client.LoginAttempts(name, 10),
+		client.AccountTwoFactorEnabled(name),
+		client.AccountPasskeys(name),
+		client.AccountSendSuspension(name),
+		client.AccountStorage(name),
 	]);
+	const junkStats = config.JunkFilter ? await client.AccountJunkFilterStats(name) : null;
 	// todo: show suppression list, and buttons to add/remove entries.
 	let form;
 	let fieldset;
@@ -2190,6 +3571,17 @@ const account = async (name) => {
 	let fieldsetPassword;
 	let password;
 	let passwordHint;
+	let fieldsetPasswordPolicy;
+	let fieldsetTwoFactor;
+	let requireTwoFactor;
+	let policyMinLength;
+	let policyMinEntropy;
+	let policyMaxAge;
+	let policyLockoutFailures;
+	let policyLockoutPeriod;
+	let fieldsetImpersonate;
+	let impersonateReadOnly;
+	let impersonateMinutes;
 	const xparseSize = (s) => {
 		s = s.toLowerCase();
 		let mult = 1;
@@ -2214,7 +3606,13 @@ const account = async (name) => {
 		}
 		return v * mult;
 	};
-	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Accounts', '#accounts'), name), config.LoginDisabled ? dom.p(box(yellow, 'Warning: Login for this account is disabled with message: ' + config.LoginDisabled)) : [], dom.h2('Addresses'), dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Action'))), dom.tbody(Object.keys(config.Destinations || {}).length === 0 ? dom.tr(dom.td(attr.colspan('2'), '(None, login disabled)')) : [], Object.keys(config.Destinations || {}).map(k => {
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Accounts', '#accounts'), name), config.LoginDisabled ? dom.p(box(yellow, 'Warning: Login for this account is disabled with message: ' + config.LoginDisabled)) : [], sendSuspension ? dom.p(box(red, 'Sending is suspended for this account since ' + sendSuspension.Suspended.toLocaleString() + ', after an anomaly in outgoing messages: ' + sendSuspension.Reason + '. Submissions are rejected until sending is resumed. ', dom.clickbutton('Resume sending', attr.title('Accept submissions for this account again. Investigate whether the account was compromised first, e.g. by checking the recent outgoing messages and login attempts.'), async function click(e) {
+		if (!window.confirm('Are you sure you want to resume sending for this account?')) {
+			return;
+		}
+		await check(e.target, client.AccountSendResume(name));
+		window.location.reload(); // todo: only update the warning
+	}))) : [], dom.h2('Addresses'), dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Action'))), dom.tbody(Object.keys(config.Destinations || {}).length === 0 ? dom.tr(dom.td(attr.colspan('2'), '(None, login disabled)')) : [], Object.keys(config.Destinations || {}).map(k => {
 		let v = k;
 		const t = k.split('@');
 		if (t.length > 1) {
@@ -2248,7 +3646,7 @@ const account = async (name) => {
 	}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), dom.span('Localpart', attr.title('The localpart is the part before the "@"-sign of an email address. If empty, a catchall address is configured for the domain.')), dom.br(), localpart = dom.input()), '@', dom.label(style({ display: 'inline-block' }), dom.span('Domain'), dom.br(), domain = dom.select((domains || []).map(d => dom.option(domainName(d.Domain), domainName(d.Domain) === config.Domain ? attr.selected('') : [])))), ' ', dom.submitbutton('Add address'))), dom.br(), dom.h2('Alias (list) membership'), dom.table(dom.thead(dom.tr(dom.th('Alias address', attr.title('Messages sent to this address will be delivered to all members of the alias/list. A member does not receive a message if their address is in the message From header.')), dom.th('Subscription address'), dom.th('Allowed senders', attr.title('Whether only members can send through the alias/list, or anyone.')), dom.th('Send as alias address', attr.title('If enabled, messages can be sent with the alias address in the message "From" header.')), dom.th('Members visible', attr.title('If enabled, members can see the addresses of other members.')))), (config.Aliases || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [], (config.Aliases || []).sort((a, b) => a.Alias.LocalpartStr < b.Alias.LocalpartStr ? -1 : (domainName(a.Alias.Domain) < domainName(b.Alias.Domain) ? -1 : 1)).map(a => dom.tr(dom.td(dom.a(prewrap(a.Alias.LocalpartStr, '@', domainName(a.Alias.Domain)), attr.href('#domains/' + domainName(a.Alias.Domain) + '/alias/' + encodeURIComponent(a.Alias.LocalpartStr)))), dom.td(prewrap(a.SubscriptionAddress)), dom.td(a.Alias.PostPublic ? 'Anyone' : 'Members only'), dom.td(a.Alias.AllowMsgFrom ? 'Yes' : 'No'), dom.td(a.Alias.ListMembers ? 'Yes' : 'No'), dom.td(dom.clickbutton('Remove', async function click(e) {
 		await check(e.target, client.AliasAddressesRemove(a.Alias.LocalpartStr, domainName(a.Alias.Domain), [a.SubscriptionAddress]));
 		window.location.reload(); // todo: reload less
-	}))))), dom.br(), dom.h2('Settings'), dom.form(fieldsetSettings = dom.fieldset(dom.label(style({ display: 'block', marginBottom: '.5ex' }), dom.span('Maximum outgoing messages per day', attr.title('Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000. MaxOutgoingMessagesPerDay in configuration file.')), dom.br(), maxOutgoingMessagesPerDay = dom.input(attr.type('number'), attr.required(''), attr.value('' + (config.MaxOutgoingMessagesPerDay || 1000)))), dom.label(style({ display: 'block', marginBottom: '.5ex' }), dom.span('Maximum first-time recipients per day', attr.title('Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200. MaxFirstTimeRecipientsPerDay in configuration file.')), dom.br(), maxFirstTimeRecipientsPerDay = dom.input(attr.type('number'), attr.required(''), attr.value('' + (config.MaxFirstTimeRecipientsPerDay || 200)))), dom.label(style({ display: 'block', marginBottom: '.5ex' }), dom.span('Disk usage quota: Maximum total message size ', attr.title('Default maximum total message size in bytes for the account, overriding any globally configured default maximum size if non-zero. A negative value can be used to have no limit in case there is a limit by default. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage. Use units "k" for kilobytes, or "m", "g", "t".')), dom.br(), quotaMessageSize = dom.input(attr.value(formatQuotaSize(config.QuotaMessageSize))), ' Current usage is ', formatQuotaSize(Math.floor(diskUsage / (1024 * 1024)) * 1024 * 1024), '.'), dom.div(style({ display: 'block', marginBottom: '.5ex' }), dom.label(firstTimeSenderDelay = dom.input(attr.type('checkbox'), config.NoFirstTimeSenderDelay ? [] : attr.checked('')), ' ', dom.span('Delay deliveries from first-time senders', attr.title('To slow down potential spammers, when the message is misclassified as non-junk. Turning off the delay can be useful when the account processes messages automatically and needs fast responses.')))), dom.div(style({ display: 'block', marginBottom: '.5ex' }), dom.label(noCustomPassword = dom.input(attr.type('checkbox'), config.NoCustomPassword ? attr.checked('') : []), ' ', dom.span("Don't allow account to set a password of their choice", attr.title('If set, this account cannot set a password of their own choice, but can only set a new randomly generated password, preventing password reuse across services and use of weak passwords.')))), dom.submitbutton('Save')), async function submit(e) {
+	}))))), dom.br(), dom.h2('Settings'), dom.form(fieldsetSettings = dom.fieldset(dom.label(style({ display: 'block', marginBottom: '.5ex' }), dom.span('Maximum outgoing messages per day', attr.title('Maximum number of outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 1000. MaxOutgoingMessagesPerDay in configuration file.')), dom.br(), maxOutgoingMessagesPerDay = dom.input(attr.type('number'), attr.required(''), attr.value('' + (config.MaxOutgoingMessagesPerDay || 1000)))), dom.label(style({ display: 'block', marginBottom: '.5ex' }), dom.span('Maximum first-time recipients per day', attr.title('Maximum number of first-time recipients in outgoing messages for this account in a 24 hour window. This limits the damage to recipients and the reputation of this mail server in case of account compromise. Default 200. MaxFirstTimeRecipientsPerDay in configuration file.')), dom.br(), maxFirstTimeRecipientsPerDay = dom.input(attr.type('number'), attr.required(''), attr.value('' + (config.MaxFirstTimeRecipientsPerDay || 200)))), dom.label(style({ display: 'block', marginBottom: '.5ex' }), dom.span('Disk usage quota: Maximum total message size ', attr.title('Default maximum total message size in bytes for the account, overriding any globally configured default maximum size if non-zero. A negative value can be used to have no limit in case there is a limit by default. Attempting to add new messages to an account beyond its maximum total size will result in an error. Useful to prevent a single account from filling storage. Use units "k" for kilobytes, or "m", "g", "t".')), dom.br(), quotaMessageSize = dom.input(attr.value(formatQuotaSize(config.QuotaMessageSize))), ' Current usage is ', formatQuotaSize(Math.floor(diskUsage / (1024 * 1024)) * 1024 * 1024), '.', storage.Compressed > 0 ? ' Stored on disk with compression: ' + formatQuotaSize(Math.floor(storage.StoredSize / (1024 * 1024)) * 1024 * 1024) + ', ' + storage.Compressed + ' of ' + storage.Messages + ' messages compressed.' : []), dom.div(style({ display: 'block', marginBottom: '.5ex' }), dom.label(firstTimeSenderDelay = dom.input(attr.type('checkbox'), config.NoFirstTimeSenderDelay ? [] : attr.checked('')), ' ', dom.span('Delay deliveries from first-time senders', attr.title('To slow down potential spammers, when the message is misclassified as non-junk. Turning off the delay can be useful when the account processes messages automatically and needs fast responses.')))), dom.div(style({ display: 'block', marginBottom: '.5ex' }), dom.label(noCustomPassword = dom.input(attr.type('checkbox'), config.NoCustomPassword ? attr.checked('') : []), ' ', dom.span("Don't allow account to set a password of their choice", attr.title('If set, this account cannot set a password of their own choice, but can only set a new randomly generated password, preventing password reuse across services and use of weak passwords.')))), dom.submitbutton('Save')), async function submit(e) {
 		e.stopPropagation();
 		e.preventDefault();
 		await check(fieldsetSettings, (async () => await client.AccountSettingsSave(name, parseInt(maxOutgoingMessagesPerDay.value) || 0, parseInt(maxFirstTimeRecipientsPerDay.value) || 0, xparseSize(quotaMessageSize.value), firstTimeSenderDelay.checked, noCustomPassword.checked))());
@@ -2274,10 +3672,68 @@ const account = async (name) => {
 		await check(fieldsetPassword, client.SetPassword(name, password.value));
 		window.alert('Password has been changed.');
 		formPassword.reset();
-	}), dom.br(), dom.h2('TLS public keys', attr.title('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.')), dom.table(dom.thead(dom.tr(dom.th('Login address'), dom.th('Name'), dom.th('Type'), dom.th('No IMAP "preauth"', attr.title('New IMAP immediate TLS connections authenticated with a client certificate are automatically switched to "authenticated" state with an untagged IMAP "preauth" message by default. IMAP connections have a state machine specifying when commands are allowed. Authenticating is not allowed while in the "authenticated" state. Enable this option to work around clients that would try to authenticated anyway.')), dom.th('Fingerprint'))), dom.tbody(tlspubkeys?.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'None')), (tlspubkeys || []).map(tpk => {
+	}), dom.br(), dom.clickbutton('Require password change', attr.title('Mark the current password as expired. Login attempts on all protocols except the account web interface are rejected until the account sets a new password. Sessions are logged out.'), async function click(e) {
+		if (!window.confirm('Are you sure? The account can only login to the account web interface to set a new password.')) {
+			return;
+		}
+		await check(e.target, client.PasswordRequireChange(name));
+		window.alert('Password change is now required.');
+	}), dom.br(), dom.h2('Password policy'), dom.form(fieldsetPasswordPolicy = dom.fieldset(dom.label(style({ display: 'inline-block' }), dom.span('Minimum length', attr.title('Minimum number of characters for passwords the account sets through the account web interface. Passwords are always at least 8 characters.')), dom.br(), policyMinLength = dom.input(attr.type('number'), attr.min('0'), attr.value(config.PasswordPolicy?.MinLength ? '' + config.PasswordPolicy.MinLength : ''))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Minimum strength (bits)', attr.title('Minimum estimated strength in bits of passwords the account sets, based on the length and the kinds of characters. E.g. 50. Randomly generated passwords have around 75 bits.')), dom.br(), policyMinEntropy = dom.input(attr.type('number'), attr.min('0'), attr.value(config.PasswordPolicy?.MinEntropy ? '' + config.PasswordPolicy.MinEntropy : ''))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Maximum age', attr.title('If set, passwords expire after this period, and login attempts with an expired password are rejected on all protocols except the account web interface. E.g. 13w or 90d.')), dom.br(), policyMaxAge = dom.input(attr.value(formatDuration(config.PasswordPolicy?.MaxAge || 0)))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Lockout after failures', attr.title('If set, after this many consecutive failed login attempts for the account, on any protocol, login attempts are rejected during the lockout period, even with the correct password.')), dom.br(), policyLockoutFailures = dom.input(attr.type('number'), attr.min('0'), attr.value(config.PasswordPolicy?.LockoutFailures ? '' + config.PasswordPolicy.LockoutFailures : ''))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('Lockout period', attr.title('Period during which login attempts are rejected after too many failed attempts. Default 15m.')), dom.br(), policyLockoutPeriod = dom.input(attr.value(formatDuration(config.PasswordPolicy?.LockoutPeriod || 0)))), ' ', dom.submitbutton('Save')), async function submit(e) {
+		e.stopPropagation();
+		e.preventDefault();
+		await check(fieldsetPasswordPolicy, (async () => {
+			const policy = {
+				MinLength: parseInt(policyMinLength.value) || 0,
+				MinEntropy: parseInt(policyMinEntropy.value) || 0,
+				MaxAge: parseDuration(policyMaxAge.value),
+				LockoutFailures: parseInt(policyLockoutFailures.value) || 0,
+				LockoutPeriod: parseDuration(policyLockoutPeriod.value),
+			};
+			const empty = !policy.MinLength && !policy.MinEntropy && !policy.MaxAge && !policy.LockoutFailures && !policy.LockoutPeriod;
+			await client.AccountPasswordPolicySave(name, empty ? null : policy);
+		})());
+	}), dom.br(), dom.h2('Two-factor authentication'), dom.p(twoFactorEnabled ? 'Enabled by the account. ' : 'Not enabled by the account. ', twoFactorEnabled ? dom.clickbutton('Reset two-factor authentication', attr.title('Remove the two-factor authentication configuration of the account, e.g. after the account lost their authenticator app. App passwords are kept.'), async function click(e) {
+		if (!window.confirm('Are you sure? The account can login to the web interfaces with just the password.')) {
+			return;
+		}
+		await check(e.target, client.AccountTOTPRemove(name));
+		window.location.reload();
+	}) : []), dom.form(fieldsetTwoFactor = dom.fieldset(dom.label(attr.title('If set, the account must set up two-factor authentication. Until then, it can only login to the account web interface. IMAP/SMTP and other protocols require app passwords. Two-factor authentication can also be required for all accounts of a domain.'), requireTwoFactor = dom.input(attr.type('checkbox'), config.RequireTwoFactor ? attr.checked('') : []), ' Require two-factor authentication'), ' ', dom.submitbutton('Save')), async function submit(e) {
+		e.stopPropagation();
+		e.preventDefault();
+		await check(fieldsetTwoFactor, client.AccountRequireTwoFactorSave(name, requireTwoFactor.checked));
+	}), dom.br(), dom.h2('Passkeys', attr.title('Passkeys are registered by the account in the account web interface. They can be used to login to the web interfaces without password, or as second factor.')), dom.table(dom.thead(dom.tr(dom.th('Name'), dom.th('Login address'), dom.th('Created'), dom.th('Last used'), dom.th('Action'))), dom.tbody(passkeys?.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'None')), (passkeys || []).map(pk => dom.tr(dom.td(pk.Name), dom.td(pk.LoginAddress), dom.td(age(pk.Created, false, 0)), dom.td(pk.LastUsed.getTime() > 0 ? age(pk.LastUsed, false, 0) : 'never'), dom.td(dom.clickbutton('Remove', async function click(e) {
+		if (!window.confirm('Are you sure? The account can no longer use the passkey to login.')) {
+			return;
+		}
+		await check(e.target, client.AccountPasskeyRemove(name, pk.CredentialID));
+		window.location.reload(); // todo: only refresh the list
+	})))))), dom.br(), dom.h2('TLS public keys', attr.title('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.')), dom.table(dom.thead(dom.tr(dom.th('Login address'), dom.th('Name'), dom.th('Type'), dom.th('No IMAP "preauth"', attr.title('New IMAP immediate TLS connections authenticated with a client certificate are automatically switched to "authenticated" state with an untagged IMAP "preauth" message by default. IMAP connections have a state machine specifying when commands are allowed. Authenticating is not allowed while in the "authenticated" state. Enable this option to work around clients that would try to authenticated anyway.')), dom.th('Fingerprint'))), dom.tbody(tlspubkeys?.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'None')), (tlspubkeys || []).map(tpk => {
 		const row = dom.tr(dom.td(tpk.LoginAddress), dom.td(tpk.Name), dom.td(tpk.Type), dom.td(tpk.NoIMAPPreauth ? 'Enabled' : ''), dom.td(tpk.Fingerprint));
 		return row;
-	}))), dom.br(), RoutesEditor('account-specific', transports, config.Routes || [], async (routes) => await client.AccountRoutesSave(name, routes)), dom.br(), dom.h2('Danger'), dom.div(config.LoginDisabled ? [
+	}))), dom.br(), RoutesEditor('account-specific', transports, config.Routes || [], async (routes) => await client.AccountRoutesSave(name, routes)), dom.br(), OutgoingFooterEditor('account', config.OutgoingFooter, async (footer) => await client.AccountOutgoingFooterSave(name, footer)), dom.br(), dom.h2('Junk filter', attr.title('The junk filter is trained with messages that are marked as junk or not junk, e.g. by moving them to or out of the Junk mailbox through IMAP, webmail or the webapi.')), junkStats ? [
+		dom.p('Trained with ', '' + junkStats.Account.Hams, ' ham and ', '' + junkStats.Account.Spams, ' spam messages, ', '' + junkStats.Account.Words, ' words.'),
+		junkStats.Shared ? dom.p('Shared junk filter of the domain of the account: trained with ', '' + junkStats.Shared.Hams, ' ham and ', '' + junkStats.Shared.Spams, ' spam messages, ', '' + junkStats.Shared.Words, ' words.') : [],
+		dom.clickbutton('Retrain from scratch', attr.title('Remove the junk filter of the account and train a new junk filter with all messages marked as junk or not junk. The account is locked while retraining. The shared junk filter of the domain is not changed.'), async function click(e) {
+			if (!window.confirm('Are you sure? Retraining can take a while for large accounts.')) {
+				return;
+			}
+			const [total, trained] = await check(e.target, client.AccountJunkFilterRetrain(name));
+			window.alert('Trained ' + trained + ' of ' + total + ' messages.');
+			window.location.reload();
+		}),
+	] : dom.p('No junk filter configured for this account.'), dom.p('See ', dom.a(attr.href('#accounts/l/' + name + '/junkverdicts'), 'junk analysis verdicts'), ' of messages delivered to this account.'), dom.br(), config.JunkFilter ? [
+		SpamActionsEditor('account', config.JunkFilter, async (sa) => await client.AccountSpamActionsSave(name, sa || { TagThreshold: 0, JunkThreshold: 0, HighAction: '' })),
+		dom.br(),
+	] : [], dom.h2('Impersonate', attr.title('Open webmail as this account, e.g. for troubleshooting, without needing the password. The webmail interface shows a banner during the session. The start of the session and all actions in it are recorded in the audit log.')), dom.form(fieldsetImpersonate = dom.fieldset(dom.label(style({ display: 'inline-block' }), impersonateReadOnly = dom.input(attr.type('checkbox'), attr.checked('')), ' View only'), ' ', dom.label(style({ display: 'inline-block' }), 'Duration in minutes ', impersonateMinutes = dom.input(attr.type('number'), attr.min('1'), attr.max('480'), attr.value('30'), attr.required(''))), ' ', dom.submitbutton('Open webmail')), async function submit(e) {
+		e.stopPropagation();
+		e.preventDefault();
+		const [token, webmailPath] = await check(fieldsetImpersonate, client.AccountImpersonate(name, impersonateReadOnly.checked, parseInt(impersonateMinutes.value)));
+		const url = webmailPath + 'impersonate?token=' + encodeURIComponent(token);
+		if (!window.open(url, '_blank')) {
+			window.location.href = url;
+		}
+	}), dom.br(), dom.h2('Danger'), dom.div(config.LoginDisabled ? [
 		box(yellow, 'Account login is currently disabled.'),
 		dom.clickbutton('Enable account login', async function click(e) {
 			if (window.confirm('Are you sure you want to enable login to this account?')) {
@@ -2357,6 +3813,31 @@ const formatDuration = (v, goDuration) => {
 	}
 	return format(second, 's');
 };
+const popupDKIMRotation = (d, st) => {
+	const fmtTime = (t) => t.getTime() <= 0 ? '-' : t.toISOString();
+	const tasks = st.Tasks || [];
+	const history = st.History || [];
+	const close = popup(style({ maxWidth: '80em' }), dom.h1('DKIM key rotation'), !st.Config ? dom.p('Automatic key rotation is not configured for this domain. Configure "Rotation" in the DKIM section of the domain in domains.conf, or "DKIMRotation" in its DNSProvisioning section.') : [
+		dom.p('Every ' + formatDuration(st.Config.Interval || 90 * 24 * 3600 * 1000 * 1000 * 1000) + ', new DKIM keys are added. ', st.Provisioned ? 'Their DNS records are published through DNS provisioning. ' : 'Their DNS records must be added manually, see tasks below. ', 'Once their DNS records are found, signing switches to the new keys. The old keys are removed after a retain period.'),
+		dom.table(dom.tr(dom.td('Previous rotation'), dom.td(fmtTime(st.Rotated))), dom.tr(dom.td('Rotation in progress'), dom.td(st.Added.getTime() <= 0 ? 'No' : 'Replacing ' + (st.Old || []).join(', ') + ' with ' + (st.New || []).join(', ') + ', added ' + fmtTime(st.Added) + (st.Switched.getTime() <= 0 ? '' : ', switched ' + fmtTime(st.Switched)))), dom.tr(dom.td('Next stage'), dom.td(st.NextStage ? st.NextStage + ', due ' + fmtTime(st.Next) : '-'))),
+	], tasks.length === 0 ? [] : [
+		dom.h2('Tasks'),
+		dom.p('Make these changes to DNS. Signing only switches to new keys after their records are found in DNS.'),
+		dom.table(dom.thead(dom.tr(dom.th('Action'), dom.th('Name'), dom.th('Type'), dom.th('Value'))), dom.tbody(tasks.map(t => dom.tr(dom.td(t.Action), dom.td(t.Name), dom.td('TXT'), dom.td(style({ wordBreak: 'break-all' }), t.Value))))),
+		!tasks.find(t => t.Action === 'remove') ? [] : dom.div(style({ marginTop: '1ex' }), dom.clickbutton('Mark old records as removed', async function click(e) {
+			await check(e.target, client.DomainDKIMRecordsRemoved(d));
+			close();
+		})),
+	], dom.h2('History'), dom.table(dom.thead(dom.tr(dom.th('Time'), dom.th('Event'), dom.th('Selectors'), dom.th('Details'))), dom.tbody(history.length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [], history.map(e => dom.tr(dom.td(fmtTime(e.Time)), dom.td(e.Kind), dom.td((e.Selectors || []).join(', ')), dom.td(style({ whiteSpace: 'pre-wrap', wordBreak: 'break-all' }), e.Text))))));
+};
+const popupMTASTSRollout = (d, domainConfig, r) => {
+	const close = popup(dom.h1('MTA-STS staged rollout'), dom.p('Start with a policy in mode "testing". Remote mail servers report failures through TLS reporting, but still deliver when TLS cannot be verified. Once TLS reports for at least a week show no failures, the policy can be enforced. Promoting sets a new policy ID.'), dom.p('Current policy: ', r.Policy ? r.Policy.Mode + ', max age ' + formatDuration(r.Policy.MaxAge) + ', policy ID ' + r.Policy.PolicyID : 'none'), dom.table(dom.tr(dom.td('Days with reports in mode testing'), dom.td(style({ textAlign: 'right' }), '' + r.TestingDays)), dom.tr(dom.td('Successful sessions'), dom.td(style({ textAlign: 'right' }), '' + r.TestingSuccess)), dom.tr(dom.td('Failed sessions'), dom.td(style({ textAlign: 'right' }), '' + r.TestingFailure)), dom.tr(dom.td('Failed sessions, past week'), dom.td(style({ textAlign: 'right' }), r.RecentFailure > 0 ? style({ backgroundColor: red }) : [], '' + r.RecentFailure)), Object.entries(r.FailureTypes || {}).map(t => dom.tr(dom.td('- ' + t[0]), dom.td(style({ textAlign: 'right' }), '' + t[1]))), dom.tr(dom.td('Most recent report period end'), dom.td(r.LastReportPeriod.getTime() <= 0 ? '-' : r.LastReportPeriod.toISOString()))), dom.ul((r.Reasons || []).map(s => dom.li(s))), !r.NextMode ? [] : dom.div(dom.clickbutton('Promote to ' + r.NextMode, r.Ready ? [] : attr.disabled(''), async function click(e) {
+		const policyID = await check(e.target, client.DomainMTASTSPromote(d));
+		window.alert('Policy promoted to mode ' + r.NextMode + ' with policy ID ' + policyID + '. ' + (domainConfig.DNSProvisioning ? 'The MTA-STS DNS record has been updated.' : "Don't forget to update the MTA-STS DNS record with the new policy ID, see suggested DNS records."));
+		close();
+		window.location.reload(); // todo: reload just the mtasts policy
+	})));
+};
 const domain = async (d) => {
 	const end = new Date();
 	const start = new Date(new Date().getTime() - 30 * 24 * 3600 * 1000);
@@ -2382,6 +3863,8 @@ const domain = async (d) => {
 	let descrText;
 	let clientSettingsDomainFieldset;
 	let clientSettingsDomain;
+	let requireTwoFactorFieldset;
+	let requireTwoFactor;
 	let localpartFieldset;
 	let localpartCaseSensitive;
 	let dmarcFieldset;
@@ -2439,7 +3922,7 @@ const domain = async (d) => {
 			window.location.reload(); // todo: reload only dkim section
 		}, fieldset = dom.fieldset(dom.div(style({ display: 'flex', gap: '1em' }), dom.div(dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Selector', attr.title('Used in the DKIM-Signature header, and used to form a DNS record under ._domainkey.<domain>.'), dom.div(selector = dom.input(attr.required(''), attr.value(defaultSelector())))), dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Algorithm', attr.title('For signing messages. RSA is common at the time of writing, not all mail servers recognize ed25519 signature.'), dom.div(algorithm = dom.select(dom.option('rsa'), dom.option('ed25519')))), dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Hash', attr.title("Used in signing messages. Don't use sha1 unless you understand the consequences."), dom.div(hash = dom.select(dom.option('sha256')))), dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Canonicalization - header', attr.title('Canonicalization processes the message headers before signing. Relaxed allows more whitespace changes, making it more likely for DKIM signatures to validate after transit through servers that make whitespace modifications. Simple is more strict.'), dom.div(canonHeader = dom.select(dom.option('relaxed'), dom.option('simple')))), dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Canonicalization - body', attr.title('Like canonicalization for headers, but for the bodies.'), dom.div(canonBody = dom.select(dom.option('relaxed'), dom.option('simple')))), dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Signature lifetime', attr.title('How long a signature remains valid. Should be as long as a message may take to be delivered. The signature must be valid at the time a message is being delivered to the final destination.'), dom.div(lifetime = dom.input(attr.value('3d'), attr.required('')))), dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Seal headers', attr.title("DKIM-signatures cover headers. If headers are not sealed, additional message headers can be added with the same key without invalidating the signature. This may confuse software about which headers are trustworthy. Sealing is the safer option."), dom.div(seal = dom.input(attr.type('checkbox'), attr.checked(''))))), dom.div(dom.label(style({ display: 'block', marginBottom: '1ex' }), 'Headers (optional)', attr.title('Headers to sign. If left empty, a set of standard headers are signed. The (standard set of) headers are most easily edited after creating the selector/key.'), dom.div(headers = dom.textarea(attr.rows('15')))))), dom.div(dom.submitbutton('Add')))));
 	};
-	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Domain ' + domainString(dnsdomain)), domainConfig.Disabled ? dom.p(box(yellow, 'Warning: Domain is disabled. Incoming/outgoing messages involving this domain are rejected and ACME for new TLS certificates is disabled.')) : [], dom.ul(dom.li(dom.a('Required DNS records', attr.href('#domains/' + d + '/dnsrecords'))), dom.li(dom.a('Check current actual DNS records and domain configuration', attr.href('#domains/' + d + '/dnscheck')))), dom.br(), dom.h2('Client configuration'), dom.p('If autoconfig/autodiscover does not work with an email client, use the settings below for this domain. Authenticate with email address and password. ', dom.span('Explicitly configure', attr.title('To prevent authentication mechanism downgrade attempts that may result in clients sending plain text passwords to a MitM.')), ' the first supported authentication mechanism: SCRAM-SHA-256-PLUS, SCRAM-SHA-1-PLUS, SCRAM-SHA-256, SCRAM-SHA-1, CRAM-MD5.'), dom.table(dom.thead(dom.tr(dom.th('Protocol'), dom.th('Host'), dom.th('Port'), dom.th('Listener'), dom.th('Note'))), dom.tbody((clientConfigs.Entries || []).map(e => dom.tr(dom.td(e.Protocol), dom.td(domainString(e.Host)), dom.td('' + e.Port), dom.td('' + e.Listener), dom.td('' + e.Note))))), dom.br(), dom.h2('DMARC aggregate reports summary'), renderDMARCSummaries(dmarcSummaries || []), dom.br(), dom.h2('TLS reports summary'), renderTLSRPTSummaries(tlsrptSummaries || []), dom.br(), dom.h2('Addresses'), dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Account'), dom.th('Action'))), dom.tbody(Object.entries(localpartAccounts).map(t => dom.tr(dom.td(prewrap(t[0]) || '(catchall)'), dom.td(dom.a(t[1], attr.href('#accounts/l/' + t[1]))), dom.td(dom.clickbutton('Remove', async function click(e) {
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Domain ' + domainString(dnsdomain)), domainConfig.Disabled ? dom.p(box(yellow, 'Warning: Domain is disabled. Incoming/outgoing messages involving this domain are rejected and ACME for new TLS certificates is disabled.')) : [], dom.ul(dom.li(dom.a('Required DNS records', attr.href('#domains/' + d + '/dnsrecords'))), dom.li(dom.a('Check current actual DNS records and domain configuration', attr.href('#domains/' + d + '/dnscheck'))), dom.li(dom.a('Readiness checklist, for setting up a new domain', attr.href('#domains/' + d + '/readiness')))), dom.br(), dom.h2('Client configuration'), dom.p('If autoconfig/autodiscover does not work with an email client, use the settings below for this domain. Authenticate with email address and password. ', dom.span('Explicitly configure', attr.title('To prevent authentication mechanism downgrade attempts that may result in clients sending plain text passwords to a MitM.')), ' the first supported authentication mechanism: SCRAM-SHA-256-PLUS, SCRAM-SHA-1-PLUS, SCRAM-SHA-256, SCRAM-SHA-1, CRAM-MD5.'), dom.table(dom.thead(dom.tr(dom.th('Protocol'), dom.th('Host'), dom.th('Port'), dom.th('Listener'), dom.th('Note'))), dom.tbody((clientConfigs.Entries || []).map(e => dom.tr(dom.td(e.Protocol), dom.td(domainString(e.Host)), dom.td('' + e.Port), dom.td('' + e.Listener), dom.td('' + e.Note))))), dom.br(), dom.h2('DMARC aggregate reports summary'), renderDMARCSummaries(dmarcSummaries || []), dom.br(), dom.h2('TLS reports summary'), renderTLSRPTSummaries(tlsrptSummaries || []), dom.br(), dom.h2('Addresses'), dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Account'), dom.th('Action'))), dom.tbody(Object.entries(localpartAccounts).map(t => dom.tr(dom.td(prewrap(t[0]) || '(catchall)'), dom.td(dom.a(t[1], attr.href('#accounts/l/' + t[1]))), dom.td(dom.clickbutton('Remove', async function click(e) {
 		e.preventDefault();
 		if (!window.confirm('Are you sure you want to remove this address? If it is a member of an alias, it will be removed from the alias.')) {
 			return;
@@ -2471,7 +3954,7 @@ const domain = async (d) => {
 	}, aliasFieldset = dom.fieldset(style({ display: 'flex', alignItems: 'flex-start', gap: '1em' }), dom.label(dom.div('Localpart', attr.title('The localpart is the part before the "@"-sign of an address.')), aliasLocalpart = dom.input(attr.required('')), '@', domainName(dnsdomain), ' '), dom.label(dom.div('Addresses', attr.title('One members address per line, full address of form localpart@domain. At least one address required.')), aliasAddresses = dom.textarea(attr.required(''), attr.rows('1'), function focus() {
 		aliasAddresses.setAttribute('rows', '5');
 		aliasAddText.style.visibility = 'visible';
-	})), dom.div(dom.div('\u00a0'), dom.submitbutton('Add alias', attr.title('Alias will be added and the config reloaded.')), aliasAddText = dom.p(style({ visibility: 'hidden', fontStyle: 'italic' }), 'Messages sent to aliases are delivered to each member address of the alias, like a mailing list. For an additional address for an account, add it as regular address (see above).')))), dom.br(), RoutesEditor('domain-specific', transports, domainConfig.Routes || [], async (routes) => await client.DomainRoutesSave(d, routes)), dom.br(), dom.h2('Settings'), dom.form(async function submit(e) {
+	})), dom.div(dom.div('\u00a0'), dom.submitbutton('Add alias', attr.title('Alias will be added and the config reloaded.')), aliasAddText = dom.p(style({ visibility: 'hidden', fontStyle: 'italic' }), 'Messages sent to aliases are delivered to each member address of the alias, like a mailing list. For an additional address for an account, add it as regular address (see above).')))), dom.br(), RoutesEditor('domain-specific', transports, domainConfig.Routes || [], async (routes) => await client.DomainRoutesSave(d, routes)), dom.br(), OutgoingFooterEditor('domain', domainConfig.OutgoingFooter, async (footer) => await client.DomainOutgoingFooterSave(d, footer)), dom.br(), SpamActionsEditor('domain', domainConfig.SpamActions, async (sa) => await client.DomainSpamActionsSave(d, sa)), dom.br(), SpamtrapsEditor(domainConfig.Spamtraps || [], async (localparts) => await client.DomainSpamtrapsSave(d, localparts)), dom.br(), MessageTemplatesEditor(domainConfig.MessageTemplates || [], async (templates) => await client.DomainMessageTemplatesSave(d, templates)), dom.br(), dom.h2('Settings'), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(descrFieldset, client.DomainDescriptionSave(d, descrText.value));
@@ -2479,7 +3962,11 @@ const domain = async (d) => {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(clientSettingsDomainFieldset, client.DomainClientSettingsDomainSave(d, clientSettingsDomain.value));
-	}, clientSettingsDomainFieldset = dom.fieldset(style({ display: 'flex', gap: '1em' }), dom.label(attr.title('Hostname for client settings instead of the mail server hostname. E.g. mail.<domain>. For future migration to another mail operator without requiring all clients to update their settings, it is convenient to have client settings that reference a subdomain of the hosted domain instead of the hostname of the server where the mail is currently hosted. If empty, the hostname of the mail server is used for client configurations. Unicode name.'), dom.div('Client settings domain'), clientSettingsDomain = dom.input(attr.value(domainConfig.ClientSettingsDomain), style({ width: '30em' }))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))))), (() => {
+	}, clientSettingsDomainFieldset = dom.fieldset(style({ display: 'flex', gap: '1em' }), dom.label(attr.title('Hostname for client settings instead of the mail server hostname. E.g. mail.<domain>. For future migration to another mail operator without requiring all clients to update their settings, it is convenient to have client settings that reference a subdomain of the hosted domain instead of the hostname of the server where the mail is currently hosted. If empty, the hostname of the mail server is used for client configurations. Unicode name.'), dom.div('Client settings domain'), clientSettingsDomain = dom.input(attr.value(domainConfig.ClientSettingsDomain), style({ width: '30em' }))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))))), dom.form(style({ marginTop: '1ex' }), async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(requireTwoFactorFieldset, client.DomainRequireTwoFactorSave(d, requireTwoFactor.checked));
+	}, requireTwoFactorFieldset = dom.fieldset(dom.label(attr.title('If set, accounts with this domain as their default domain must set up two-factor authentication. Until then, they can only login to the account web interface. IMAP/SMTP and other protocols require app passwords.'), requireTwoFactor = dom.input(attr.type('checkbox'), domainConfig.RequireTwoFactor ? attr.checked('') : []), ' Require two-factor authentication for accounts'), ' ', dom.submitbutton('Save'))), (() => {
 		let separatorViews = [];
 		let separatorsBox;
 		const addSeparatorView = (s) => {
@@ -2570,7 +4057,19 @@ const domain = async (d) => {
 			mode = mtastsMode.value;
 			maxAge = parseDuration(mtastsMaxAge.value);
 			mx = mtastsMX.value ? mtastsMX.value.split('\n') : [];
-			if (domainConfig.MTASTS?.PolicyID === mtastsPolicyID.value && !window.confirm('Are you sure you want to save the policy without updating the policy ID? Remote servers may hold on to the old cached policies. Policy IDs should be changed when the policy is changed. Remember to first update the policy here, then publish the new policy ID in DNS.')) {
+			if (domainConfig.MTASTS?.PolicyID === mtastsPolicyID.value) {
+				// Policy ID not changed by user, let the server set a new ID if the policy changed.
+				const policyID = await check(mtastsFieldset, client.DomainMTASTSPolicySave(d, mode, maxAge, mx));
+				if (policyID !== domainConfig.MTASTS?.PolicyID) {
+					window.alert('Policy saved with new policy ID ' + policyID + '. ' + (domainConfig.DNSProvisioning ? 'The MTA-STS DNS record has been updated.' : "Don't forget to update the MTA-STS DNS record with the new policy ID, see suggested DNS records."));
+				}
+				mtastsPolicyID.value = policyID;
+				domainConfig.MTASTS = {
+					PolicyID: policyID,
+					Mode: mode,
+					MaxAge: maxAge,
+					MX: mx,
+				};
 				return;
 			}
 		}
@@ -2597,7 +4096,10 @@ const domain = async (d) => {
 		e.preventDefault();
 		// 20060102T150405
 		mtastsPolicyID.value = new Date().toISOString().replace(/-/g, '').replace(/:/g, '').split('.')[0];
-	})), mtastsPolicyID = dom.input(attr.value(domainConfig.MTASTS?.PolicyID || ''))), dom.label(attr.title("If set to \"enforce\", a remote SMTP server will not deliver email to us if it cannot make a WebPKI-verified SMTP STARTTLS connection. In mode \"testing\", deliveries can be done without verified TLS, but errors will be reported through TLS reporting. In mode \"none\", verified TLS is not required, used for phasing out an MTA-STS policy."), dom.div('Mode'), mtastsMode = dom.select(dom.option(''), Object.values(api.Mode).map(s => dom.option(s, domainConfig.MTASTS?.Mode === s ? attr.selected('') : [])))), dom.label(attr.title('How long a remote mail server is allowed to cache a policy. Typically 1 or several weeks. Units: s for seconds, m for minutes, h for hours, d for day, w for weeks.'), dom.div('Max age'), mtastsMaxAge = dom.input(attr.value(domainConfig.MTASTS?.MaxAge ? formatDuration(domainConfig.MTASTS?.MaxAge || 0) : ''))), dom.label(attr.title('List of server names allowed for SMTP. If empty, the configured hostname is set. Host names can contain a wildcard (*) as a leading label (matching a single label, e.g. *.example matches host.example, not sub.host.example).'), dom.div('MX hosts/patterns (optional)'), mtastsMX = dom.textarea(new String((domainConfig.MTASTS?.MX || []).join('\n')), attr.rows('' + Math.max(2, 1 + (domainConfig.MTASTS?.MX || []).length)))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))))), dom.br(), dom.h2('DKIM', attr.title('With DKIM signing, a domain is taking responsibility for (content of) emails it sends, letting receiving mail servers build up a (hopefully positive) reputation of the domain, which can help with mail delivery.')), (() => {
+	})), mtastsPolicyID = dom.input(attr.value(domainConfig.MTASTS?.PolicyID || ''))), dom.label(attr.title("If set to \"enforce\", a remote SMTP server will not deliver email to us if it cannot make a WebPKI-verified SMTP STARTTLS connection. In mode \"testing\", deliveries can be done without verified TLS, but errors will be reported through TLS reporting. In mode \"none\", verified TLS is not required, used for phasing out an MTA-STS policy."), dom.div('Mode'), mtastsMode = dom.select(dom.option(''), Object.values(api.Mode).map(s => dom.option(s, domainConfig.MTASTS?.Mode === s ? attr.selected('') : [])))), dom.label(attr.title('How long a remote mail server is allowed to cache a policy. Typically 1 or several weeks. Units: s for seconds, m for minutes, h for hours, d for day, w for weeks.'), dom.div('Max age'), mtastsMaxAge = dom.input(attr.value(domainConfig.MTASTS?.MaxAge ? formatDuration(domainConfig.MTASTS?.MaxAge || 0) : ''))), dom.label(attr.title('List of server names allowed for SMTP. If empty, the configured hostname is set. Host names can contain a wildcard (*) as a leading label (matching a single label, e.g. *.example matches host.example, not sub.host.example).'), dom.div('MX hosts/patterns (optional)'), mtastsMX = dom.textarea(new String((domainConfig.MTASTS?.MX || []).join('\n')), attr.rows('' + Math.max(2, 1 + (domainConfig.MTASTS?.MX || []).length)))), dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))))), dom.div(style({ marginTop: '1ex' }), dom.clickbutton('Staged rollout...', attr.title('Evaluate TLS reports about the policy, and promote the policy from mode testing to enforce when no recent failures are reported.'), async function click(e) {
+		const r = await check(e.target, client.DomainMTASTSRollout(d));
+		popupMTASTSRollout(d, domainConfig, r);
+	})), dom.br(), dom.h2('DKIM', attr.title('With DKIM signing, a domain is taking responsibility for (content of) emails it sends, letting receiving mail servers build up a (hopefully positive) reputation of the domain, which can help with mail delivery.')), (() => {
 		let fieldset;
 		let rows = [];
 		return dom.form(async function submit(e) {
@@ -2659,6 +4161,9 @@ const domain = async (d) => {
 			};
 		})), dom.tfoot(dom.tr(dom.td(attr.colspan('9'), dom.submitbutton('Save'), ' ', dom.clickbutton('Add key/selector', function click() {
 			popupDKIMAdd();
+		}), ' ', dom.clickbutton('Key rotation...', attr.title('Show the state and history of automatic DKIM key rotation, and DNS records to add or remove for domains without DNS provisioning.'), async function click(e) {
+			const st = await check(e.target, client.DomainDKIMRotation(d));
+			popupDKIMRotation(d, st);
 		})))))));
 	})(), dom.br(), dom.h2('External checks'), dom.ul(dom.li(link('https://internet.nl/mail/' + dnsdomain.ASCII + '/', 'Check configuration at internet.nl'))), dom.br(), dom.h2('Danger'), dom.div(domainConfig.Disabled ? [
 		box(yellow, 'Domain is currently disabled.'),
@@ -2686,29 +4191,76 @@ const domainAlias = async (d, aliasLocalpart) => {
 	if (!alias) {
 		throw new Error('alias not found');
 	}
+	const [subscribers, archive] = await Promise.all([
+		alias.List ? client.ListSubscribers(aliasLocalpart, d) : Promise.resolve([]),
+		alias.List?.Archive ? client.ListArchive(aliasLocalpart, d) : Promise.resolve([]),
+	]);
 	let aliasFieldset;
 	let postPublic;
 	let listMembers;
 	let allowMsgFrom;
+	let listFieldset;
+	let postPolicy;
+	let listHeaders;
+	let owners;
 	let addFieldset;
 	let addAddress;
+	let mlistFieldset;
+	let mlistEnabled;
+	let mlistSubscribe;
+	let mlistArchive;
+	let mlistBounceLimit;
+	let subscriberFieldset;
+	let subscriberAddress;
+	let subscriberDigest;
 	let delFieldset;
+	const nowSecs = new Date().getTime() / 1000;
 	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Domain ' + domainString(domain.Domain), '#domains/' + d), 'Alias ' + aliasLocalpart + '@' + domainName(domain.Domain)), dom.h2('Alias'), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		check(aliasFieldset, client.AliasUpdate(aliasLocalpart, d, postPublic.checked, listMembers.checked, allowMsgFrom.checked));
-	}, aliasFieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.label(postPublic = dom.input(attr.type('checkbox'), alias.PostPublic ? attr.checked('') : []), ' Public, anyone is allowed to send to the alias, instead of only members of the alias', attr.title('Based on address in message From header, which is assumed to be DMARC-like verified. If this setting is disabled and a non-member sends a message to the alias, the message is rejected.')), dom.label(listMembers = dom.input(attr.type('checkbox'), alias.ListMembers ? attr.checked('') : []), ' Members can list other members'), dom.label(allowMsgFrom = dom.input(attr.type('checkbox'), alias.AllowMsgFrom ? attr.checked('') : []), ' Allow messages to use the alias address in the message From header'), dom.div(style({ marginTop: '1ex' }), dom.submitbutton('Save')))), dom.br(), dom.h2('Members'), dom.p('Members receive messages sent to the alias. If a member address is in the message From header, the member will not receive the message.'), dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Account'), dom.th())), dom.tbody((alias.Addresses || []).map((address, index) => {
+	}, aliasFieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.label(postPublic = dom.input(attr.type('checkbox'), alias.PostPublic ? attr.checked('') : []), ' Public, anyone is allowed to send to the alias, instead of only members of the alias', attr.title('Based on address in message From header, which is assumed to be DMARC-like verified. If this setting is disabled and a non-member sends a message to the alias, the message is rejected.')), dom.label(listMembers = dom.input(attr.type('checkbox'), alias.ListMembers ? attr.checked('') : []), ' Members can list other members'), dom.label(allowMsgFrom = dom.input(attr.type('checkbox'), alias.AllowMsgFrom ? attr.checked('') : []), ' Allow messages to use the alias address in the message From header'), dom.div(style({ marginTop: '1ex' }), dom.submitbutton('Save')))), dom.br(), dom.h2('Distribution list'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(listFieldset, client.AliasListSave(aliasLocalpart, d, postPolicy.value, listHeaders.checked, owners.value.split('\n').map(s => s.trim()).filter(s => s)));
+	}, listFieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.label('Allowed senders', attr.title('Overrides the public setting above if set. For moderated lists, messages from others than the owners are only delivered to the owners, who can resend approved messages to the list.'), dom.div(postPolicy = dom.select([['', 'Based on public setting above'], ['members', 'Members only'], ['anyone', 'Anyone'], ['moderated', 'Moderated by owners']].map(t => dom.option(t[1], attr.value(t[0]), alias.PostPolicy === t[0] ? attr.selected('') : []))))), dom.label(listHeaders = dom.input(attr.type('checkbox'), alias.ListHeaders ? attr.checked('') : []), ' Add List-Id, List-Post and List-Unsubscribe headers to delivered messages'), dom.label('Owners', attr.title('Addresses of local accounts that can add and remove members in the account web interface, and that moderate a moderated list. One address per line.'), dom.div(owners = dom.textarea(attr.rows('3'), (alias.Owners || []).join('\n')))), dom.div(style({ marginTop: '1ex' }), dom.submitbutton('Save')))), dom.br(), dom.h2('Members'), dom.p('Members receive messages sent to the alias. If a member address is in the message From header, the member will not receive the message.'), dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Account'), dom.th('Delivery', attr.title('Delivery preference of the member, set by the member in the account web interface.')), dom.th())), dom.tbody((alias.Addresses || []).map((address, index) => {
 		const pa = (alias.ParsedAddresses || [])[index];
-		return dom.tr(dom.td(prewrap(address)), dom.td(dom.a(pa.AccountName, attr.href('#accounts/l/' + pa.AccountName))), dom.td(dom.clickbutton('Remove', async function click(e) {
+		const m = (alias.Members || {})[address];
+		return dom.tr(dom.td(prewrap(address)), dom.td(dom.a(pa.AccountName, attr.href('#accounts/l/' + pa.AccountName))), dom.td(m?.NoDelivery ? 'None' : (m?.Mailbox ? 'Mailbox ' + m.Mailbox : 'Default')), dom.td(dom.clickbutton('Remove', async function click(e) {
 			await check(e.target, client.AliasAddressesRemove(aliasLocalpart, d, [address]));
 			window.location.reload(); // todo: reload less
 		})));
-	})), dom.tfoot(dom.tr(dom.td(attr.colspan('3'), dom.form(async function submit(e) {
+	})), dom.tfoot(dom.tr(dom.td(attr.colspan('4'), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		await check(addFieldset, client.AliasAddressesAdd(aliasLocalpart, d, addAddress.value.split('\n').map(s => s.trim()).filter(s => s)));
 		window.location.reload(); // todo: reload less
-	}, addFieldset = dom.fieldset(addAddress = dom.textarea(attr.required(''), attr.rows('1'), attr.placeholder('localpart@domain'), function focus() { addAddress.setAttribute('rows', '5'); }), ' ', dom.submitbutton('Add', style({ verticalAlign: 'top' })))))))), dom.br(), dom.h2('Danger'), dom.form(async function submit(e) {
+	}, addFieldset = dom.fieldset(addAddress = dom.textarea(attr.required(''), attr.rows('1'), attr.placeholder('localpart@domain'), function focus() { addAddress.setAttribute('rows', '5'); }), ' ', dom.submitbutton('Add', style({ verticalAlign: 'top' })))))))), dom.br(), dom.h2('Mailing list'), dom.p('A mailing list also has subscribers, which can be external addresses. Subscribers receive messages sent to the list through the queue, and can post to the list like members. Email commands are sent to the list address with a catchall separator, e.g. "+subscribe", and subscriptions can be managed on the list/ page of the account web interface.'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const list = mlistEnabled.checked ? { Subscribe: mlistSubscribe.checked, Archive: mlistArchive.checked, BounceLimit: parseInt(mlistBounceLimit.value) || 0 } : null;
+		await check(mlistFieldset, client.AliasMailingListSave(aliasLocalpart, d, list));
+		window.location.reload(); // todo: reload less
+	}, mlistFieldset = dom.fieldset(style({ display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.label(mlistEnabled = dom.input(attr.type('checkbox'), alias.List ? attr.checked('') : []), ' Enabled', attr.title('Requires a localpart catchall separator for the domain, for the email commands.')), dom.label(mlistSubscribe = dom.input(attr.type('checkbox'), alias.List?.Subscribe ? attr.checked('') : []), ' Anyone can subscribe, confirmed with a message to the subscribing address'), dom.label(mlistArchive = dom.input(attr.type('checkbox'), alias.List?.Archive ? attr.checked('') : []), ' Keep messages in an archive'), dom.label('Bounce limit', attr.title('Number of delivery failures after which a subscriber is unsubscribed. Failures are forgotten after 30 days without failures. Default 5.'), dom.div(mlistBounceLimit = dom.input(attr.type('number'), attr.min('0'), attr.value(alias.List?.BounceLimit ? '' + alias.List.BounceLimit : ''), attr.placeholder('5')))), dom.div(style({ marginTop: '1ex' }), dom.submitbutton('Save')))), !alias.List ? [] : [
+		dom.br(),
+		dom.h2('Subscribers'),
+		dom.table(dom.thead(dom.tr(dom.th('Address'), dom.th('Confirmed'), dom.th('Digest'), dom.th('Bounces', attr.title('Number of delivery failures, reset after 30 days without failures.')), dom.th('Created'), dom.th())), dom.tbody(subscribers.length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No subscribers.')) : [], subscribers.map(s => dom.tr(dom.td(prewrap(s.Address)), dom.td(s.Confirmed ? 'Yes' : 'No'), dom.td(s.Digest ? 'Yes' : 'No'), dom.td('' + s.Bounces), dom.td(age(s.Created, false, nowSecs)), dom.td(dom.clickbutton('Remove', async function click(e) {
+			await check(e.target, client.ListSubscriberRemove(aliasLocalpart, d, s.ID));
+			window.location.reload(); // todo: reload less
+		}))))), dom.tfoot(dom.tr(dom.td(attr.colspan('6'), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			await check(subscriberFieldset, client.ListSubscriberAdd(aliasLocalpart, d, subscriberAddress.value, subscriberDigest.checked));
+			window.location.reload(); // todo: reload less
+		}, subscriberFieldset = dom.fieldset(subscriberAddress = dom.input(attr.required(''), attr.placeholder('localpart@domain')), ' ', dom.label(style({ display: 'inline' }), subscriberDigest = dom.input(attr.type('checkbox')), ' Digest'), ' ', dom.submitbutton('Add subscriber', attr.title('Subscribers added by an admin are confirmed immediately.')))))))),
+	], !alias.List?.Archive ? [] : [
+		dom.br(),
+		dom.h2('Archive'),
+		dom.table(dom.thead(dom.tr(dom.th('Received'), dom.th('From'), dom.th('Subject'), dom.th('Size'), dom.th())), dom.tbody(archive.length === 0 ? dom.tr(dom.td(attr.colspan('5'), 'No messages.')) : [], archive.map(m => dom.tr(dom.td(age(m.Received, false, nowSecs)), dom.td(prewrap(m.From)), dom.td(prewrap(m.Subject)), dom.td(formatSize(m.Size)), dom.td(dom.clickbutton('View', async function click(e) {
+			const text = await check(e.target, client.ListArchiveMessage(aliasLocalpart, d, m.ID));
+			popup(dom.h1('Message'), dom.pre(style({ whiteSpace: 'pre-wrap', maxWidth: '80em' }), text));
+		})))))),
+	], dom.br(), dom.h2('Danger'), dom.form(async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		if (!confirm('Are you sure you want to remove this alias?')) {
@@ -2725,6 +4277,44 @@ const domainDNSRecords = async (d) => {
 	]);
 	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Domain ' + domainString(dnsdomain), '#domains/' + d), 'DNS Records'), dom.h1('Required DNS records'), dom.pre(dom._class('literal'), (records || []).join('\n')), dom.br());
 };
+const domainReadiness = async (d) => {
+	const [readiness, dnsdomain] = await Promise.all([
+		client.DomainReadiness(d),
+		client.ParseDomain(d),
+	]);
+	const statusColor = (status) => status === 'ok' ? green : (status === 'warning' ? yellow : red);
+	const render = (r) => {
+		const items = r.Items || [];
+		return [
+			r.Checked.getTime() > 0 ? dom.p(r.Ready ? box(green, 'Domain is ready.') : box(yellow, 'Domain is not ready yet.'), ' ', '' + r.Done + ' of ' + items.length + ' checks OK. Last checked ', age(r.Checked, false, 0), '.', r.Checking ? ' Checking again...' : []) : dom.p('Checking...'),
+			r.Error ? dom.p(box(red, 'Error: ' + r.Error)) : [],
+			items.length === 0 ? [] : dom.table(dom.thead(dom.tr(dom.th('Check'), dom.th('Status'), dom.th('Details'))), dom.tbody(items.map(it => dom.tr(dom.td(it.Name), dom.td(box(statusColor(it.Status), it.Status)), dom.td((it.Errors || []).length === 0 ? [] : dom.ul((it.Errors || []).map(s => dom.li(s))), (it.Warnings || []).length === 0 ? [] : dom.ul((it.Warnings || []).map(s => dom.li(s))), (it.Instructions || []).map(s => dom.pre(dom._class('literal'), style({ maxWidth: '60em' }), s))))))),
+			(r.Records || []).length === 0 ? [] : [
+				dom.h2('DNS records'),
+				dom.p('All DNS records for the domain. Records that are already present can be skipped.'),
+				dom.pre(dom._class('literal'), (r.Records || []).join('\n')),
+			],
+		];
+	};
+	let resultsElem;
+	const root = dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Domain ' + domainString(dnsdomain), '#domains/' + d), 'Readiness'), dom.p('Checklist of DNS records and configuration for sending and receiving email with this domain. Add the DNS records below, this page checks again periodically while open.'), resultsElem = dom.div(render(readiness)));
+	// Poll for new results while this page is shown.
+	const poll = async () => {
+		if (!document.body.contains(root)) {
+			return;
+		}
+		try {
+			const r = await client.DomainReadiness(d);
+			dom._kids(resultsElem, render(r));
+		}
+		catch (err) {
+			console.log('polling domain readiness', err);
+		}
+		window.setTimeout(poll, 5 * 1000);
+	};
+	window.setTimeout(poll, 5 * 1000);
+	return root;
+};
 const domainDNSCheck = async (d) => {
 	const [checks, dnsdomain] = await Promise.all([
 		client.CheckDomain(d),
@@ -2934,15 +4524,41 @@ const period = (start, end) => {
 	}
 	return dom.span(beginstr + ' - ' + endstr, title);
 };
+const renderDMARCAnalytics = (a) => {
+	const pct = (n, total) => total === 0 ? '-' : (Math.round(n * 1000 / total) / 10) + '%';
+	const maxDay = Math.max(1, ...(a.Days || []).map(d => d.Total));
+	const sourcesTable = (sources) => dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Source IP'), dom.th('Messages'), dom.th('DMARC fail'), dom.th('DKIM fail', attr.title('Messages without aligned DKIM pass.')), dom.th('SPF fail', attr.title('Messages without aligned SPF pass.')), dom.th('Header from', attr.title('Domains in From-header of messages.')))), dom.tbody((sources || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [], (sources || []).map(src => dom.tr(dom.td(src.IP), dom.td(style({ textAlign: 'right' }), '' + src.Total), dom.td(style({ textAlign: 'right' }), src.Fail > 0 ? style({ backgroundColor: red }) : [], '' + src.Fail + ' (' + pct(src.Fail, src.Total) + ')'), dom.td(style({ textAlign: 'right' }), '' + src.DKIMFail), dom.td(style({ textAlign: 'right' }), '' + src.SPFFail), dom.td((src.Domains || []).join(', '))))));
+	return [
+		dom.h2('Analytics'),
+		dom.p('' + a.Reports + ' reports about ' + a.Total + ' messages. ', 'DMARC pass: ' + a.Pass + ' (' + pct(a.Pass, a.Total) + '), fail: ' + a.Fail + ' (' + pct(a.Fail, a.Total) + '). ', 'Quarantined: ' + a.Quarantine + ', rejected: ' + a.Reject + '.'),
+		dom.table(dom.thead(dom.tr(dom.th(), dom.th('Aligned pass', attr.title('Passed and aligned with the From-header domain, as required for DMARC.')), dom.th('Authentication pass', attr.title('Passed, whether aligned or not.')))), dom.tbody(dom.tr(dom.td('DKIM'), dom.td('' + a.DKIMAlignedPass + ' (' + pct(a.DKIMAlignedPass, a.Total) + ')'), dom.td('' + a.DKIMAuthPass + ' (' + pct(a.DKIMAuthPass, a.Total) + ')')), dom.tr(dom.td('SPF'), dom.td('' + a.SPFAlignedPass + ' (' + pct(a.SPFAlignedPass, a.Total) + ')'), dom.td('' + a.SPFAuthPass + ' (' + pct(a.SPFAuthPass, a.Total) + ')')))),
+		dom.br(),
+		dom.h3('Messages per day'),
+		dom.table(dom.thead(dom.tr(dom.th('Day (UTC)', attr.title('Day the reporting period started.')), dom.th('Messages'), dom.th('DMARC fail'), dom.th('DKIM fail'), dom.th('SPF fail'), dom.th())), dom.tbody((a.Days || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No reports.')) : [], (a.Days || []).map(d => dom.tr(dom.td(d.Day), dom.td(style({ textAlign: 'right' }), '' + d.Total), dom.td(style({ textAlign: 'right' }), '' + d.Fail + ' (' + pct(d.Fail, d.Total) + ')'), dom.td(style({ textAlign: 'right' }), '' + d.DKIMFail), dom.td(style({ textAlign: 'right' }), '' + d.SPFFail), dom.td(attr.title('Messages passing DMARC in green, failing in red.'), dom.div(style({ display: 'inline-block', height: '1em', width: ((d.Total - d.Fail) * 20 / maxDay) + 'em', backgroundColor: green })), dom.div(style({ display: 'inline-block', height: '1em', width: (d.Fail * 20 / maxDay) + 'em', backgroundColor: red }))))))),
+		dom.br(),
+		dom.h3('Top sources'),
+		sourcesTable(a.Sources),
+		dom.br(),
+		dom.h3('Top failing sources', attr.title('Source IPs sending most messages failing DMARC, possibly abusing the domain, or legitimate services that are not configured for DKIM/SPF.')),
+		sourcesTable(a.FailingSources),
+		dom.br(),
+		dom.h3('Reporters'),
+		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Organisation'), dom.th('Reports'), dom.th('Messages'), dom.th('DMARC fail'))), dom.tbody((a.Reporters || []).length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [], (a.Reporters || []).map(r => dom.tr(dom.td(r.OrgName), dom.td(style({ textAlign: 'right' }), '' + r.Reports), dom.td(style({ textAlign: 'right' }), '' + r.Total), dom.td(style({ textAlign: 'right' }), '' + r.Fail + ' (' + pct(r.Fail, r.Total) + ')'))))),
+		dom.br(),
+	];
+};
 const domainDMARC = async (d) => {
 	const end = new Date();
+	const nowSecs = end.getTime() / 1000;
 	const start = new Date(new Date().getTime() - 30 * 24 * 3600 * 1000);
-	const [reports, dnsdomain] = await Promise.all([
+	const [reports, analytics, failureReports, dnsdomain] = await Promise.all([
 		client.DMARCReports(start, end, d),
+		client.DMARCAnalytics(start, end, d),
+		client.DMARCFailureReports(start, end, d),
 		client.Domain(d),
 	]);
-	// todo future: table sorting? period selection (last day, 7 days, 1 month, 1 year, custom period)? collapse rows for a report? show totals per report? a simple bar graph to visualize messages and dmarc/dkim/spf fails? similar for TLSRPT.
-	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Domain ' + domainString(dnsdomain), '#domains/' + d), 'DMARC aggregate reports'), dom.p('DMARC reports are periodically sent by other mail servers that received an email message with a "From" header with our domain. Domains can have a DMARC DNS record that asks other mail servers to send these aggregate reports for analysis.'), dom.p('Below the DMARC aggregate reports for the past 30 days.'), (reports || []).length === 0 ? dom.div('No DMARC reports for domain.') :
+	// todo future: table sorting? period selection (last day, 7 days, 1 month, 1 year, custom period)? collapse rows for a report? show totals per report? similar for TLSRPT.
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Domain ' + domainString(dnsdomain), '#domains/' + d), 'DMARC aggregate reports'), dom.p('DMARC reports are periodically sent by other mail servers that received an email message with a "From" header with our domain. Domains can have a DMARC DNS record that asks other mail servers to send these aggregate reports for analysis.'), dom.p('Below the DMARC aggregate reports for the past 30 days.'), renderDMARCAnalytics(analytics), dom.h2('Reports'), (reports || []).length === 0 ? dom.div('No DMARC reports for domain.') :
 		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('ID'), dom.th('Organisation', attr.title('Organization that sent the DMARC report.')), dom.th('Period (UTC)', attr.title('Period this reporting period is about. Mail servers are recommended to stick to whole UTC days.')), dom.th('Policy', attr.title('The DMARC policy that the remote mail server had fetched and applied to the message. A policy that changed during the reporting period may result in unexpected policy evaluations.')), dom.th('Source IP', attr.title('Remote IP address of session at remote mail server.')), dom.th('Messages', attr.title('Total messages that the results apply to.')), dom.th('Result', attr.title('DMARC evaluation result.')), dom.th('ADKIM', attr.title('DKIM alignment. For a pass, one of the DKIM signatures that pass must be strict/relaxed-aligned with the domain, as specified by the policy.')), dom.th('ASPF', attr.title('SPF alignment. For a pass, the SPF policy must pass and be strict/relaxed-aligned with the domain, as specified by the policy.')), dom.th('SMTP to', attr.title('Domain of destination address, as specified during the SMTP session.')), dom.th('SMTP from', attr.title('Domain of originating address, as specified during the SMTP session.')), dom.th('Header from', attr.title('Domain of address in From-header of message.')), dom.th('Auth Results', attr.title('Details of DKIM and/or SPF authentication results. DMARC requires at least one aligned DKIM or SPF pass.')))), dom.tbody((reports || []).map(r => {
 			const m = r.ReportMetadata;
 			let policy = [];
@@ -3048,7 +4664,17 @@ const domainDMARC = async (d) => {
 				}
 				return rows;
 			});
-		}))));
+		}))), dom.br(), dom.h2('Failure reports'), dom.p('Failure reports are about individual messages that failed DMARC, sent by mail servers if the DMARC DNS record of the domain has a "ruf" field with reporting addresses. Few mail servers send them. Below the failure reports received in the past 30 days.'), (failureReports || []).length === 0 ? dom.div('No DMARC failure reports for domain.') :
+		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('ID'), dom.th('Received'), dom.th('Reporter', attr.title('Domain of the From address of the report message.')), dom.th('Source IP', attr.title('IP address of the mail server that sent the failing message.')), dom.th('Mail from', attr.title('SMTP MAIL FROM of the failing message.')), dom.th('Auth failure', attr.title('Authentication mechanism that failed.')), dom.th('Aligned', attr.title('Mechanisms that passed with alignment.')), dom.th('Delivery result', attr.title('What the reporting mail server did with the message.')))), dom.tbody((failureReports || []).map(r => dom.tr(dom.td(dom.a(attr.href('#domains/' + d + '/dmarc/failure/' + r.ID), '' + r.ID)), dom.td(age(r.Received, false, nowSecs)), dom.td(r.FromDomain), dom.td(r.SourceIP), dom.td(r.OriginalMailFrom), dom.td(r.AuthFailure), dom.td(r.IdentityAlignment), dom.td(r.DeliveryResult))))));
+};
+const domainDMARCFailureReport = async (d, reportID) => {
+	const [report, dnsdomain] = await Promise.all([
+		client.DMARCFailureReportID(d, reportID),
+		client.Domain(d),
+	]);
+	const header = report.Header;
+	report.Header = '';
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Domain ' + domainString(dnsdomain), '#domains/' + d), crumblink('DMARC aggregate reports', '#domains/' + d + '/dmarc'), 'Failure report ' + reportID), dom.p('Below is the failure report as received from the remote mail server.'), dom.div(dom._class('literal'), JSON.stringify(report, null, '\t')), dom.br(), dom.h2('Message header'), dom.p('Header of the message that failed DMARC, possibly redacted by the reporter.'), dom.div(dom._class('literal'), header || '(none)'));
 };
 const domainDMARCReport = async (d, reportID) => {
 	const [report, dnsdomain] = await Promise.all([
@@ -3157,12 +4783,36 @@ const renderTLSRPTSummaries = (summaries) => {
 			dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Policy domain', attr.title('Policy domain the report is about. The recipient domain for MTA-STS, the TLSA base domain for DANE.')), dom.th('Successes', attr.title('Number of successful SMTP STARTTLS sessions.')), dom.th('Failures', attr.title('Number of failed SMTP STARTTLS sessions.')), dom.th('Failure details', attr.title('Details about connection failures.')))), dom.tbody(summaries.map(r => dom.tr(dom.td(dom.a(attr.href('#tlsrpt/reports/' + domainName(r.PolicyDomain)), attr.title('See report details.'), domainName(r.PolicyDomain))), dom.td(style({ textAlign: 'right' }), '' + r.Success), dom.td(style({ textAlign: 'right' }), '' + r.Failure), dom.td(!r.ResultTypeCounts ? [] : Object.entries(r.ResultTypeCounts).map(kv => kv[0] + ': ' + kv[1]).join('; '))))))
 	];
 };
+const renderTLSRPTStats = (s, failureTypes) => {
+	const pct = (n, total) => total === 0 ? '-' : (Math.round(n * 1000 / total) / 10) + '%';
+	const alignRight = style({ textAlign: 'right' });
+	const total = s.Success + s.Failure;
+	return [
+		dom.h2('Statistics'),
+		dom.p('' + s.Reports + ' reports about ' + total + ' sessions. ', 'Successful: ' + s.Success + ', failed: ' + s.Failure + ' (' + pct(s.Failure, total) + ').'),
+		dom.h3('Failures by type'),
+		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Category', attr.title('Kind of problem: STARTTLS not supported, certificate problems, DANE (TLSA/DNSSEC) failures, MTA-STS policy failures, or other.')), dom.th('Result type'), dom.th('Failed sessions'), dom.th('Reports', attr.title('Number of reports mentioning this result type.')), dom.th('MX hosts', attr.title('Receiving MX hosts mentioned in failure details.')), dom.th('Reason codes'))), dom.tbody((s.ResultTypes || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No failures.')) : [], (s.ResultTypes || []).map(rt => dom.tr(dom.td(rt.Category), dom.td(rt.ResultType), dom.td(alignRight, '' + rt.Failure + ' (' + pct(rt.Failure, total) + ')'), dom.td(alignRight, '' + rt.Reports), dom.td((rt.MXHosts || []).join(', ')), dom.td((rt.Reasons || []).join(', ')))))),
+		dom.br(),
+		dom.h3('Sessions per day'),
+		dom.table(dom.thead(dom.tr(dom.th('Day (UTC)', attr.title('Day the reporting period started.')), dom.th('Successful'), dom.th('Failed'))), dom.tbody((s.Days || []).length === 0 ? dom.tr(dom.td(attr.colspan('3'), 'No reports.')) : [], (s.Days || []).map(d => dom.tr(dom.td(d.Day), dom.td(alignRight, '' + d.Success), dom.td(alignRight, d.Failure > 0 ? style({ backgroundColor: red }) : [], '' + d.Failure + ' (' + pct(d.Failure, d.Success + d.Failure) + ')'))))),
+		dom.br(),
+		dom.h3('Reporters'),
+		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Organization'), dom.th('Reports'), dom.th('Successful'), dom.th('Failed'))), dom.tbody((s.Reporters || []).length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [], (s.Reporters || []).map(r => dom.tr(dom.td(r.OrganizationName), dom.td(alignRight, '' + r.Reports), dom.td(alignRight, '' + r.Success), dom.td(alignRight, '' + r.Failure))))),
+		dom.br(),
+		dom.h3('Failure types seen'),
+		dom.p('An alert message is delivered to the TLS reporting mailbox (or postmaster) when a report contains a failure type not seen before for the domain.'),
+		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Result type'), dom.th('First seen'), dom.th('Last seen'), dom.th('Failed sessions'))), dom.tbody(failureTypes.length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [], failureTypes.map(ft => dom.tr(dom.td(ft.ResultType), dom.td(ft.First.toISOString()), dom.td(ft.Last.toISOString()), dom.td(alignRight, '' + ft.Sessions))))),
+		dom.br(),
+	];
+};
 const domainTLSRPT = async (d) => {
 	const end = new Date();
 	const start = new Date(new Date().getTime() - 30 * 24 * 3600 * 1000);
-	const [records, dnsdomain] = await Promise.all([
+	const [records, dnsdomain, stats, failureTypes] = await Promise.all([
 		client.TLSReports(start, end, d),
 		client.ParseDomain(d),
+		client.TLSRPTStats(start, end, d),
+		client.TLSRPTFailureTypes(d),
 	]);
 	const policyType = (policy) => {
 		let s = policy.Type;
@@ -3174,7 +4824,7 @@ const domainTLSRPT = async (d) => {
 		}
 		return s;
 	};
-	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('TLSRPT', '#tlsrpt'), crumblink('Reports', '#tlsrpt/reports'), 'Domain ' + domainString(dnsdomain)), dom.p('TLSRPT (TLS reporting) is a mechanism to request feedback from other mail servers about TLS connections to your mail server. If is typically used along with MTA-STS and/or DANE to enforce that SMTP connections are protected with TLS. Mail servers implementing TLSRPT will typically send a daily report with both successful and failed connection counts, including details about failures.'), dom.p('Below the TLS reports for the past 30 days.'), (records || []).length === 0 ? dom.div('No TLS reports for domain.') :
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('TLSRPT', '#tlsrpt'), crumblink('Reports', '#tlsrpt/reports'), 'Domain ' + domainString(dnsdomain)), dom.p('TLSRPT (TLS reporting) is a mechanism to request feedback from other mail servers about TLS connections to your mail server. If is typically used along with MTA-STS and/or DANE to enforce that SMTP connections are protected with TLS. Mail servers implementing TLSRPT will typically send a daily report with both successful and failed connection counts, including details about failures.'), renderTLSRPTStats(stats, failureTypes || []), dom.h2('Reports'), dom.p('Below the TLS reports for the past 30 days.'), (records || []).length === 0 ? dom.div('No TLS reports for domain.') :
 		dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Report', attr.colspan('3')), dom.th('Policy', attr.colspan('3')), dom.th('Failure Details', attr.colspan('8'))), dom.tr(dom.th('ID'), dom.th('From', attr.title('SMTP mail from from which we received the report.')), dom.th('Period (UTC)', attr.title('Period this reporting period is about. Mail servers are recommended to stick to whole UTC days.')), dom.th('Policy', attr.title('The policy applied, typically STSv1.')), dom.th('Successes', attr.title('Total number of successful TLS connections for policy.')), dom.th('Failures', attr.title('Total number of failed TLS connections for policy.')), dom.th('Result Type', attr.title('Type of failure.')), dom.th('Sending MTA', attr.title('IP of sending MTA.')), dom.th('Receiving MX Host'), dom.th('Receiving MX HELO'), dom.th('Receiving IP'), dom.th('Count', attr.title('Number of TLS connections that failed with these details.')), dom.th('More', attr.title('Optional additional information about the failure.')), dom.th('Code', attr.title('Optional API error code relating to the failure.')))), dom.tbody((records || []).map(record => {
 			const r = record.Report;
 			let nrows = 0;
@@ -3287,12 +4937,84 @@ const dnsbl = async () => {
 		dnsbl(); // Render page again.
 	}, fieldset = dom.fieldset(dom.div('One per line'), dom.div(style({ marginBottom: '.5ex' }), monitorTextarea = dom.textarea(style({ width: '20rem' }), attr.rows('' + Math.max(5, 1 + (monitorZones || []).length)), new String((monitorZones || []).map(zone => domainName(zone)).join('\n'))), dom.div('Examples: sbl.spamhaus.org or bl.spamcop.net')), dom.div(dom.submitbutton('Save')))));
 };
+const senderReputation = async () => {
+	const senders = await client.SenderReputations('', false);
+	const nowSecs = new Date().getTime() / 1000;
+	let fieldset;
+	let senderType;
+	let value;
+	let override;
+	const ratio = (n, total) => total ? '' + n + ' of ' + total : '-';
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Sender reputation'), dom.p('Reputation of sending domains (organizational domain of the message From address) and IPs (IPv6 per /64) of incoming deliveries, aggregated over all accounts. The reputation is used during delivery when an account has no conclusive reputation of its own for a sender. An override takes precedence: "allow" always accepts messages, "quarantine" always delivers to the Junk mailbox.'), dom.h2('Set override'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		await check(fieldset, client.SenderReputationOverride(senderType.value, value.value, override.value));
+		window.location.reload(); // todo: only reload the list
+	}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Type', dom.br(), senderType = dom.select(dom.option('Domain', attr.value(api.SenderType.SenderDomain)), dom.option('IP', attr.value(api.SenderType.SenderIP)))), ' ', dom.label(style({ display: 'inline-block' }), 'Domain or IP', dom.br(), value = dom.input(attr.required(''))), ' ', dom.label(style({ display: 'inline-block' }), 'Override', dom.br(), override = dom.select(dom.option('Allow', attr.value(api.Override.OverrideAllow)), dom.option('Quarantine', attr.value(api.Override.OverrideQuarantine)), dom.option('None', attr.value(api.Override.OverrideNone)))), ' ', dom.submitbutton('Save'))), dom.br(), dom.h2('Senders'), dom.p('The 1000 most recently seen senders. Deliveries for domains are only counted as accepted or rejected for messages with a validated From address.'), dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Type'), dom.th('Sender'), dom.th('Last seen'), dom.th('Accepted', attr.title('Delivery attempts accepted for at least one recipient.')), dom.th('Rejected', attr.title('Delivery attempts rejected for at least one recipient, e.g. as junk.')), dom.th('Unknown recipients'), dom.th('SPF pass'), dom.th('DKIM pass'), dom.th('DMARC pass/fail'), dom.th('Junk', attr.title('Messages marked as junk by users, of all messages marked as junk or not junk.')), dom.th('Spamtraps', attr.title('Messages sent to spamtrap addresses.')), dom.th('Override'), dom.th('Action'))), dom.tbody((senders || []).length === 0 ? dom.tr(dom.td(attr.colspan('13'), 'No senders.')) : [], (senders || []).map(s => dom.tr(dom.td(s.Type), dom.td(s.Value), dom.td(age(s.Last, false, nowSecs)), dom.td(style({ textAlign: 'right' }), '' + s.Accepted), dom.td(style({ textAlign: 'right' }), '' + s.Rejected), dom.td(style({ textAlign: 'right' }), '' + s.UnknownRecipients), dom.td(style({ textAlign: 'right' }), '' + s.SPFPass), dom.td(style({ textAlign: 'right' }), '' + s.DKIMPass), dom.td(style({ textAlign: 'right' }), '' + s.DMARCPass + '/' + s.DMARCFail), dom.td(style({ textAlign: 'right' }), ratio(s.Junk, s.Junk + s.Notjunk)), dom.td(style({ textAlign: 'right' }), '' + s.Spamtraps), dom.td(s.Override ? dom.span(s.Override, attr.title('Set ' + s.OverrideUpdated.toISOString())) : ''), dom.td(s.Override ? dom.clickbutton('Remove override', async function click(e) {
+		await check(e.target, client.SenderReputationOverride(s.Type, s.Value, api.Override.OverrideNone));
+		window.location.reload(); // todo: only reload the list
+	}) : [
+		dom.clickbutton('Allow', async function click(e) {
+			await check(e.target, client.SenderReputationOverride(s.Type, s.Value, api.Override.OverrideAllow));
+			window.location.reload(); // todo: only reload the list
+		}),
+		' ',
+		dom.clickbutton('Quarantine', async function click(e) {
+			await check(e.target, client.SenderReputationOverride(s.Type, s.Value, api.Override.OverrideQuarantine));
+			window.location.reload(); // todo: only reload the list
+		}),
+	]))))));
+};
+const spfAnalysis = async () => {
+	let fieldset;
+	let domain;
+	let ip;
+	let results;
+	const renderRecord = (r) => dom.div(dom.div(dom.b(r.Domain), ': ', r.TXT ? dom.span(style({ fontFamily: 'monospace' }), r.TXT) : [], r.Error ? box(red, r.Error) : []), dom.ul((r.Mechanisms || []).map(m => dom.li(dom.span(style({ fontFamily: 'monospace' }), m.Mechanism), m.Expanded ? ' (expanded to ' + m.Expanded + ')' : [], m.DNSLookups ? dom.span(attr.title('Lookup counting against the limit of 10.'), ' +' + m.DNSLookups) : [], m.Void ? ', void lookup' : [], (m.IPs || []).length ? ': ' + (m.IPs || []).join(', ') : [], m.Error ? box(red, m.Error) : [], m.Include ? renderRecord(m.Include) : []))));
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'SPF analysis'), dom.p('Expand the SPF record of a domain fully, following includes and redirects. Evaluation of an SPF record fails when it needs more than 10 DNS lookups, or has more than 2 lookups that do not return records. If an IP is specified, it is used for expanding macros, and is evaluated against the record.'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		dom._kids(results);
+		const [analysis, status, mechanism, errstr] = await check(fieldset, client.SPFAnalyze(domain.value, ip.value));
+		dom._kids(results, dom.h2('Summary'), dom.table(dom.tr(dom.td('DNS lookups'), dom.td(analysis.DNSLookups > 10 ? box(red, '' + analysis.DNSLookups) : '' + analysis.DNSLookups, ' of max 10')), dom.tr(dom.td('Void lookups'), dom.td(analysis.VoidLookups > 2 ? box(red, '' + analysis.VoidLookups) : '' + analysis.VoidLookups, ' of max 2')), dom.tr(dom.td('DNSSEC'), dom.td(analysis.Authentic ? 'All responses DNSSEC-signed' : 'Not all responses DNSSEC-signed')), ip.value ? dom.tr(dom.td('Evaluation of IP'), dom.td(status, mechanism ? ' (mechanism ' + mechanism + ')' : [], errstr ? box(red, errstr) : [])) : []), (analysis.Errors || []).length ? [dom.h2('Errors'), dom.ul((analysis.Errors || []).map(s => dom.li(box(red, s))))] : [], (analysis.Warnings || []).length ? [dom.h2('Warnings'), dom.ul((analysis.Warnings || []).map(s => dom.li(box(yellow, s))))] : [], dom.h2('Records'), analysis.Record ? renderRecord(analysis.Record) : []);
+	}, fieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Domain', dom.br(), domain = dom.input(attr.required(''))), ' ', dom.label(style({ display: 'inline-block' }), 'IP (optional)', dom.br(), ip = dom.input()), ' ', dom.submitbutton('Analyze'))), results = dom.div());
+};
+const daneHost = async () => {
+	const r = await client.DANEHostStatus();
+	const yes = (b) => b ? '✓' : '';
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'DANE TLSA records'), dom.p('DANE TLSA records in DNS let remote mail servers verify the TLS certificate of this host, for incoming SMTP. The records must match the key of the certificate in use. Before a certificate is renewed with a new key, a TLSA record for the new key must be published, and the TTL of the previous records must expire. Publishing records for both the current and next keys allows rolling over without breaking deliveries.'), dom.table(dom.tr(dom.td('Host'), dom.td(r.Host)), dom.tr(dom.td('TLSA DNS name'), dom.td(r.Name)), dom.tr(dom.td('DNSSEC'), dom.td(r.Authentic ? 'TLSA records DNSSEC-signed' : box(yellow, 'Not DNSSEC-signed, DANE not in effect')))), (r.Errors || []).length ? [dom.h2('Errors'), dom.ul((r.Errors || []).map(s => dom.li(box(red, s))))] : [], (r.Warnings || []).length ? [dom.h2('Warnings'), dom.ul((r.Warnings || []).map(s => dom.li(box(yellow, s))))] : [], dom.br(), dom.h2('Keys'), dom.table(dom.thead(dom.tr(dom.th('Key type'), dom.th('TLSA record'), dom.th('Current', attr.title('Key of a certificate currently in use for the host, with the expiration time of the certificate.')), dom.th('Next', attr.title('Key used for new certificates requested through ACME, the first configured key of its type.')), dom.th('Configured', attr.title('Key is in HostPrivateKeyFiles.')), dom.th('Published', attr.title('Whether a TLSA record for this key is in DNS.')))), dom.tbody((r.Keys || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No keys.')) : [], (r.Keys || []).map(k => dom.tr(dom.td(k.KeyType), dom.td(style({ fontFamily: 'monospace' }), k.TLSA), dom.td(k.Current ? ['✓', k.Expires ? ', expires ' + k.Expires.toISOString() : ''] : ''), dom.td(yes(k.Next)), dom.td(yes(k.Configured)), dom.td(k.Published ? '✓' : (k.Current || k.Next ? box(red, 'no') : 'no')))))), dom.br(), dom.h2('Suggested records'), dom.p('TLSA records for the keys of current certificates and the configured keys. Only publish records when DNSSEC is enabled for the zone.'), (r.Records || []).length === 0 ? dom.p('None.') : dom.pre(dom._class('literal'), (r.Records || []).map(s => r.Name + ' TLSA ' + s + '\n').join('')), dom.br(), dom.h2('Published records'), (r.Published || []).length === 0 ? dom.p('None.') : dom.pre(dom._class('literal'), (r.Published || []).map(s => r.Name + ' TLSA ' + s + '\n').join('')));
+};
+// formatSecurity returns a description of the security guarantees that applied
+// to a delivery attempt.
+const formatSecurity = (r) => {
+	const l = [];
+	if (r.TLS) {
+		l.push(r.MTASTS || r.DANE ? 'verified tls' : 'unverified tls');
+	}
+	else if (r.RemoteMTA) {
+		l.push('plaintext');
+	}
+	if (r.MTASTS) {
+		l.push('mta-sts');
+	}
+	if (r.DANEStatus) {
+		l.push('dane ' + r.DANEStatus);
+	}
+	const lookups = r.DNSLookups || [];
+	if (lookups.length > 0) {
+		l.push('dnssec ' + lookups.filter(lu => lu.Authentic).length + '/' + lookups.length);
+	}
+	return l.join(', ');
+};
+const securityTitle = 'Security mechanisms for the delivery attempt: whether TLS was used and verified, with MTA-STS or DANE. For DANE, the reason it was or was not used, e.g. "insecure" when DNS responses for the destination were not DNSSEC-verified, "no-tlsa" when no TLSA records exist, "required" when TLS was required and verified with DANE. For the most recent attempt, the number of DNSSEC-verified DNS responses out of all DNS lookups.';
+const dnsLookupsTable = (lookups) => dom.table(dom.thead(dom.tr(dom.th('Type'), dom.th('Name'), dom.th('DNSSEC', attr.title('Whether the response was DNSSEC-verified by the recursive resolver.')), dom.th('Error'))), dom.tbody(lookups.length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'No DNS lookups.')) : [], lookups.map(lu => dom.tr(dom.td(lu.Type), dom.td(lu.Name), dom.td(lu.Authentic ? '✓' : box(yellow, 'no')), dom.td(lu.Error)))));
 const queueList = async () => {
-	let filter = { Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Hold: null, Submitted: '', NextAttempt: '', Transport: null };
+	let filter = { Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Hold: null, Submitted: '', NextAttempt: '', Transport: null, Domain: '', LastError: '' };
 	let sort = { Field: "NextAttempt", LastID: 0, Last: null, Asc: true };
-	let [holdRules, msgs0, transports] = await Promise.all([
+	let [holdRules, msgs0, count, transports] = await Promise.all([
 		client.QueueHoldRuleList(),
 		client.QueueList(filter, sort),
+		client.QueueCount(filter),
 		client.Transports(),
 	]);
 	let msgs = msgs0 || [];
@@ -3316,6 +5038,7 @@ const queueList = async () => {
 	let filterHold;
 	let filterNextAttempt;
 	let filterTransport;
+	let filterLastError;
 	let requiretlsFieldset;
 	let requiretls;
 	let transport;
@@ -3335,6 +5058,8 @@ const queueList = async () => {
 			Submitted: '',
 			NextAttempt: '',
 			Transport: null,
+			Domain: '',
+			LastError: '',
 		};
 		// Don't want to accidentally operate on all messages.
 		if ((f.IDs || []).length === 0) {
@@ -3344,10 +5069,46 @@ const queueList = async () => {
 	};
 	const popupDetails = (m) => {
 		const nowSecs = new Date().getTime() / 1000;
-		popup(dom.h1('Details'), dom.table(dom.tr(dom.td('Message subject'), dom.td(m.Subject))), dom.br(), dom.h2('Results'), dom.table(dom.thead(dom.tr(dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'))), dom.tbody((m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No results.')) : [], (m.Results || []).map(r => dom.tr(dom.td(age(r.Start, false, nowSecs)), dom.td(Math.round(r.Duration / 1000000) + 'ms'), dom.td(r.Success ? '✓' : ''), dom.td('' + (r.Code || '')), dom.td(r.Secode), dom.td(r.Error))))));
+		const recipient = m.RecipientLocalpart + '@' + ipdomainString(m.RecipientDomain);
+		let rewriteFieldset;
+		let rewriteRecipient;
+		let rewriteTransport;
+		let rewriteFrom;
+		let rewriteSubject;
+		let transcriptBox;
+		popup(dom.h1('Details'), dom.table(dom.tr(dom.td('Message subject'), dom.td(m.Subject))), dom.br(), dom.h2('Results'), dom.table(dom.thead(dom.tr(dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'), dom.th('Security', attr.title(securityTitle)))), dom.tbody((m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('7'), 'No results.')) : [], (m.Results || []).map(r => dom.tr(dom.td(age(r.Start, false, nowSecs)), dom.td(Math.round(r.Duration / 1000000) + 'ms'), dom.td(r.Success ? '✓' : ''), dom.td('' + (r.Code || '')), dom.td(r.Secode), dom.td(r.Error), dom.td(formatSecurity(r)))))), dom.br(), dom.h2('Transcript', attr.title('SMTP protocol transcript of the most recent connection for delivering this message, without authentication and message data.')), transcriptBox = dom.div(dom.clickbutton('Show transcript', async function click(e) {
+			const r = await check(e.target, client.QueueMsgTranscript(m.ID));
+			dom._kids(transcriptBox, !r.Transcript ? 'No transcript.' : [
+				dom.div(age(r.Start, false, nowSecs), ', ', r.RemoteMTA, r.TLS ? ', ' + r.TLS : ''),
+				dom.pre(dom._class('literal'), style({ maxWidth: '60em', whiteSpace: 'pre-wrap' }), r.Transcript),
+				dom.br(),
+				dom.h2('DNS lookups', attr.title('DNS lookups for the delivery attempt, and whether their responses were DNSSEC-verified. DANE is only used when all responses for the destination are DNSSEC-verified.')),
+				dnsLookupsTable(r.DNSLookups || []),
+			]);
+		})), dom.br(), dom.h2('Changes'), dom.table(dom.thead(dom.tr(dom.th('Time'), dom.th('Change'))), dom.tbody((m.Changes || []).length === 0 ? dom.tr(dom.td(attr.colspan('2'), 'No changes.')) : [], (m.Changes || []).map(c => dom.tr(dom.td(age(c.Time, false, nowSecs)), dom.td(c.Description))))), dom.br(), dom.h2('Rewrite', attr.title('Change the envelope or message headers before the next delivery attempt, e.g. to fix a typo in the recipient address. The message is scheduled for immediate delivery. Changing the From or Subject header removes existing DKIM signatures and signs the message again.')), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			const rw = {};
+			if (rewriteRecipient.value !== recipient) {
+				rw.Recipient = rewriteRecipient.value;
+			}
+			if (rewriteTransport.value !== (m.Transport || '')) {
+				rw.Transport = rewriteTransport.value;
+			}
+			if (rewriteFrom.value) {
+				rw.From = rewriteFrom.value;
+			}
+			if (rewriteSubject.value !== m.Subject) {
+				rw.Subject = rewriteSubject.value;
+			}
+			await check(rewriteFieldset, client.QueueMsgRewrite(m.ID, rw));
+			window.location.reload(); // todo: reload less
+		}, rewriteFieldset = dom.fieldset(dom.label(style({ display: 'inline-block' }), 'Recipient', dom.br(), rewriteRecipient = dom.input(attr.value(recipient), attr.required(''))), ' ', dom.label(style({ display: 'inline-block' }), 'Transport', dom.br(), rewriteTransport = dom.select(dom.option('(default)', attr.value('')), Object.keys(transports || []).sort().map(t => dom.option(t, t === m.Transport ? attr.selected('') : [])))), ' ', dom.label(style({ display: 'inline-block' }), dom.span('From header', attr.title('Leave empty to keep the From header. Example: "Name <user@example.org>".')), dom.br(), rewriteFrom = dom.input()), ' ', dom.label(style({ display: 'inline-block' }), 'Subject', dom.br(), rewriteSubject = dom.input(attr.value(m.Subject))), ' ', dom.submitbutton('Rewrite'))));
 	};
 	let tbody = dom.tbody();
+	const countElem = dom.span();
 	const render = () => {
+		dom._kids(countElem, ' (' + count + ' matching)');
 		toggles = new Map();
 		for (const m of msgs) {
 			toggles.set(m.ID, dom.input(attr.type('checkbox'), msgs.length === 1 ? attr.checked('') : []));
@@ -3374,7 +5135,7 @@ const queueList = async () => {
 		window.alert('' + n + ' message(s) updated');
 		window.location.reload(); // todo: reload less
 	});
-	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Queue'), dom.p(dom.a(attr.href('#queue/retired'), 'Retired messages')), dom.h2('Hold rules', attr.title('Messages submitted to the queue that match a hold rule are automatically marked as "on hold", preventing delivery until explicitly taken off hold again.')), dom.form(attr.id('holdRuleForm'), async function submit(e) {
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), 'Queue'), dom.p(dom.a(attr.href('#queue/retired'), 'Retired messages'), ', ', dom.a(attr.href('#queue/hosts'), 'Remote host health')), dom.h2('Hold rules', attr.title('Messages submitted to the queue that match a hold rule are automatically marked as "on hold", preventing delivery until explicitly taken off hold again.')), dom.form(attr.id('holdRuleForm'), async function submit(e) {
 		e.preventDefault();
 		e.stopPropagation();
 		const pr = {
@@ -3428,6 +5189,8 @@ const queueList = async () => {
 			Submitted: filterSubmitted.value,
 			NextAttempt: filterNextAttempt.value,
 			Transport: !filterTransport.value ? null : (filterTransport.value === '(default)' ? '' : filterTransport.value),
+			Domain: '',
+			LastError: filterLastError.value,
 		};
 		sort = {
 			Field: sortElem.value.startsWith('nextattempt') ? 'NextAttempt' : 'Queued',
@@ -3436,13 +5199,15 @@ const queueList = async () => {
 			Asc: sortElem.value.endsWith('asc'),
 		};
 		tbody.classList.add('loadstart');
-		msgs = await check({ disabled: false }, client.QueueList(filter, sort)) || [];
+		const [l, n] = await check({ disabled: false }, Promise.all([client.QueueList(filter, sort), client.QueueCount(filter)]));
+		msgs = l || [];
+		count = n;
 		render();
-	}), dom.h2('Messages'), dom.table(dom._class('hover'), style({ width: '100%' }), dom.thead(dom.tr(dom.td(attr.colspan('2'), 'Filter'), dom.td(filterSubmitted = dom.input(attr.form('queuefilter'), style({ width: '7em' }), attr.title('Example: "<-1h" for filtering messages submitted more than 1 hour ago.'))), dom.td(filterAccount = dom.input(attr.form('queuefilter'))), dom.td(filterFrom = dom.input(attr.form('queuefilter')), attr.title('Example: "@sender.example" to filter by domain of sender.')), dom.td(filterTo = dom.input(attr.form('queuefilter')), attr.title('Example: "@recipient.example" to filter by domain of recipient.')), dom.td(), // todo: add filter by size?
+	}), dom.h2('Messages', countElem), dom.table(dom._class('hover'), style({ width: '100%' }), dom.thead(dom.tr(dom.td(attr.colspan('2'), 'Filter'), dom.td(filterSubmitted = dom.input(attr.form('queuefilter'), style({ width: '7em' }), attr.title('Example: "<-1h" for filtering messages submitted more than 1 hour ago.'))), dom.td(filterAccount = dom.input(attr.form('queuefilter'))), dom.td(filterFrom = dom.input(attr.form('queuefilter')), attr.title('Example: "@sender.example" to filter by domain of sender.')), dom.td(filterTo = dom.input(attr.form('queuefilter')), attr.title('Example: "@recipient.example" to filter by domain of recipient.')), dom.td(), // todo: add filter by size?
 	dom.td(), // todo: add filter by attempts?
 	dom.td(filterHold = dom.select(attr.form('queuefilter'), function change() {
 		filterForm.requestSubmit();
-	}, dom.option('', attr.value('')), dom.option('Yes'), dom.option('No'))), dom.td(filterNextAttempt = dom.input(attr.form('queuefilter'), style({ width: '7em' }), attr.title('Example: ">1h" for filtering messages to be delivered in more than 1 hour, or "<now" for messages to be delivered as soon as possible.'))), dom.td(), dom.td(), dom.td(filterTransport = dom.select(Object.keys(transports || {}).length === 0 ? style({ display: 'none' }) : [], attr.form('queuefilter'), function change() {
+	}, dom.option('', attr.value('')), dom.option('Yes'), dom.option('No'))), dom.td(filterNextAttempt = dom.input(attr.form('queuefilter'), style({ width: '7em' }), attr.title('Example: ">1h" for filtering messages to be delivered in more than 1 hour, or "<now" for messages to be delivered as soon as possible.'))), dom.td(), dom.td(filterLastError = dom.input(attr.form('queuefilter'), attr.title('Text in the error of the last delivery attempt, case-insensitive. Example: "greylist".'))), dom.td(filterTransport = dom.select(Object.keys(transports || {}).length === 0 ? style({ display: 'none' }) : [], attr.form('queuefilter'), function change() {
 		filterForm.requestSubmit();
 	}, dom.option(''), dom.option('(default)'), Object.keys(transports || {}).sort().map(t => dom.option(t)))), dom.td(attr.colspan('2'), style({ textAlign: 'right' }), // Less content shifting while rendering.
 	'Sort ', sortElem = dom.select(attr.form('queuefilter'), function change() {
@@ -3516,6 +5281,15 @@ const queueList = async () => {
 		window.location.reload(); // todo: only refresh the list
 	})))));
 };
+const queueHostHealth = async () => {
+	const hosts = await client.QueueHostHealthList() || [];
+	const nowSecs = new Date().getTime() / 1000;
+	const when = (d) => d.getTime() <= 0 ? '-' : age(d, false, nowSecs);
+	return dom.div(crumbs(crumblink('Mox Admin', '#'), crumblink('Queue', '#queue'), 'Remote host health'), dom.p('Outcome of delivery attempts to remote hosts. After failed attempts, a host is put in a cooldown period that doubles with each consecutive failure, up to an hour. During the cooldown, other MX hosts with the same preference are tried first.'), dom.table(dom._class('hover'), dom.thead(dom.tr(dom.th('Host'), dom.th('Last attempt'), dom.th('Last success'), dom.th('Last failure'), dom.th('Attempts'), dom.th('Successes'), dom.th('Connect failures'), dom.th('TLS failures'), dom.th('Temporary failures'), dom.th('Consecutive failures'), dom.th('Cooldown until'), dom.th('Last error'), dom.th('Action'))), dom.tbody(hosts.length === 0 ? dom.tr(dom.td(attr.colspan('13'), 'No delivery attempts recorded.')) : [], hosts.map(hh => dom.tr(dom.td(hh.Host), dom.td(when(hh.LastAttempt)), dom.td(when(hh.LastSuccess)), dom.td(when(hh.LastFailure)), dom.td(style({ textAlign: 'right' }), '' + hh.Attempts), dom.td(style({ textAlign: 'right' }), '' + hh.Successes), dom.td(style({ textAlign: 'right' }), '' + hh.ConnectFailures), dom.td(style({ textAlign: 'right' }), '' + hh.TLSFailures), dom.td(style({ textAlign: 'right' }), '' + hh.TemporaryFailures), dom.td(style({ textAlign: 'right' }), '' + hh.ConsecutiveFailures), dom.td(hh.CooldownUntil.getTime() > nowSecs * 1000 ? age(hh.CooldownUntil, true, nowSecs) : '-'), dom.td(hh.LastError || '-'), dom.td(dom.clickbutton('Reset', attr.title('Remove the health record for this host, ending its cooldown period.'), async function click(e) {
+		await check(e.target, client.QueueHostHealthReset(hh.Host));
+		window.location.reload(); // todo: reload less
+	})))))));
+};
 const retiredList = async () => {
 	let filter = { Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Submitted: '', LastActivity: '', Transport: null };
 	let sort = { Field: "LastActivity", LastID: 0, Last: null, Asc: false };
@@ -3537,7 +5311,12 @@ const retiredList = async () => {
 	let filterSuccess;
 	const popupDetails = (m) => {
 		const nowSecs = new Date().getTime() / 1000;
-		popup(dom.h1('Details'), dom.table(dom.tr(dom.td('Message subject'), dom.td(m.Subject))), dom.br(), dom.h2('Results'), dom.table(dom.thead(dom.tr(dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'))), dom.tbody((m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No results.')) : [], (m.Results || []).map(r => dom.tr(dom.td(age(r.Start, false, nowSecs)), dom.td(Math.round(r.Duration / 1000000) + 'ms'), dom.td(r.Success ? '✓' : ''), dom.td('' + (r.Code || '')), dom.td(r.Secode), dom.td(r.Error))))));
+		const lastResult = (m.Results || []).length > 0 ? (m.Results || [])[(m.Results || []).length - 1] : null;
+		popup(dom.h1('Details'), dom.table(dom.tr(dom.td('Message subject'), dom.td(m.Subject))), dom.br(), dom.h2('Results'), dom.table(dom.thead(dom.tr(dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'), dom.th('Security', attr.title(securityTitle)))), dom.tbody((m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('7'), 'No results.')) : [], (m.Results || []).map(r => dom.tr(dom.td(age(r.Start, false, nowSecs)), dom.td(Math.round(r.Duration / 1000000) + 'ms'), dom.td(r.Success ? '✓' : ''), dom.td('' + (r.Code || '')), dom.td(r.Secode), dom.td(r.Error), dom.td(formatSecurity(r)))))), !lastResult || !lastResult.DNSLookups ? [] : [
+			dom.br(),
+			dom.h2('DNS lookups', attr.title('DNS lookups for the most recent delivery attempt, and whether their responses were DNSSEC-verified.')),
+			dnsLookupsTable(lastResult.DNSLookups),
+		]);
 	};
 	let tbody = dom.tbody();
 	const render = () => {
@@ -4161,6 +5940,7 @@ const webserver = async () => {
 const init = async () => {
 	let curhash;
 	[moxversion, moxgoos, moxgoarch] = await client.Version();
+	[adminName, adminUser] = await client.AdminSelf();
 	const hashChange = async () => {
 		if (curhash === window.location.hash) {
 			return;
@@ -4182,9 +5962,24 @@ const init = async () => {
 			else if (h === 'loglevels') {
 				root = await loglevels();
 			}
+			else if (h === 'twofactor') {
+				root = await twofactor();
+			}
+			else if (h === 'adminusers') {
+				root = await adminUsers();
+			}
+			else if (h === 'auditlog') {
+				root = await auditlog();
+			}
+			else if (h === 'signup') {
+				root = await signup();
+			}
 			else if (h === 'accounts') {
 				root = await accounts();
 			}
+			else if (h === 'accounts/storage') {
+				root = await accountsStorage();
+			}
 			else if (h === 'accounts/loginattempts') {
 				root = await loginattempts();
 			}
@@ -4194,6 +5989,12 @@ const init = async () => {
 			else if (t[0] === 'accounts' && t.length === 4 && t[1] === 'l' && t[3] === 'loginattempts') {
 				root = await accountloginattempts(t[2]);
 			}
+			else if (t[0] === 'accounts' && t.length === 4 && t[1] === 'l' && t[3] === 'junkverdicts') {
+				root = await accountjunkverdicts(t[2], false);
+			}
+			else if (t[0] === 'accounts' && t.length === 5 && t[1] === 'l' && t[3] === 'junkverdicts' && t[4] === 'junk') {
+				root = await accountjunkverdicts(t[2], true);
+			}
 			else if (t[0] === 'domains' && t.length === 2) {
 				root = await domain(t[1]);
 			}
@@ -4206,9 +6007,15 @@ const init = async () => {
 			else if (t[0] === 'domains' && t.length === 4 && t[2] === 'dmarc' && parseInt(t[3])) {
 				root = await domainDMARCReport(t[1], parseInt(t[3]));
 			}
+			else if (t[0] === 'domains' && t.length === 5 && t[2] === 'dmarc' && t[3] === 'failure' && parseInt(t[4])) {
+				root = await domainDMARCFailureReport(t[1], parseInt(t[4]));
+			}
 			else if (t[0] === 'domains' && t.length === 3 && t[2] === 'dnscheck') {
 				root = await domainDNSCheck(t[1]);
 			}
+			else if (t[0] === 'domains' && t.length === 3 && t[2] === 'readiness') {
+				root = await domainReadiness(t[1]);
+			}
 			else if (t[0] === 'domains' && t.length === 3 && t[2] === 'dnsrecords') {
 				root = await domainDNSRecords(t[1]);
 			}
@@ -4218,6 +6025,9 @@ const init = async () => {
 			else if (h === 'queue/retired') {
 				root = await retiredList();
 			}
+			else if (h === 'queue/hosts') {
+				root = await queueHostHealth();
+			}
 			else if (h === 'webhookqueue') {
 				root = await hooksList();
 			}
@@ -4260,6 +6070,15 @@ const init = async () => {
 			else if (h === 'dnsbl') {
 				root = await dnsbl();
 			}
+			else if (h === 'senderreputation') {
+				root = await senderReputation();
+			}
+			else if (h === 'spf') {
+				root = await spfAnalysis();
+			}
+			else if (h === 'dane') {
+				root = await daneHost();
+			}
 			else if (h === 'routes') {
 				root = await globalRoutes();
 			}
@@ -9,12 +9,33 @@ let moxversion: string
 let moxgoos: string
 let moxgoarch: string
 
+// Admin user of the session, name is empty for login with the admin password.
+let adminName: string
+let adminUser: api.AdminUser
+
 const login = async (reason: string) => {
 	return new Promise<string>((resolve: (v: string) => void, _) => {
 		const origFocus = document.activeElement
 		let reasonElem: HTMLElement
 		let fieldset: HTMLFieldSetElement
+		let username: HTMLInputElement
 		let password: HTMLInputElement
+		let totpLabel: HTMLElement
+		let totp: HTMLInputElement
+
+		const loggedIn = (token: string) => {
+			try {
+				window.localStorage.setItem('webadmincsrftoken', token)
+			} catch (err) {
+				console.log('saving csrf token in localStorage', err)
+			}
+			root.remove()
+			if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
+				origFocus.focus()
+			}
+			resolve(token)
+		}
+
 		const root = dom.div(
 			style({position: 'absolute', top: 0, right: 0, bottom: 0, left: 0, backgroundColor: '#eee', display: 'flex', alignItems: 'center', justifyContent: 'center', zIndex: '1', animation: 'fadein .15s ease-in'}),
 			dom.div(
@@ -32,19 +53,18 @@ const login = async (reason: string) => {
 							try {
 								fieldset.disabled = true
 								const loginToken = await client.LoginPrep()
-								const token = await client.Login(loginToken, password.value)
-								try {
-									window.localStorage.setItem('webadmincsrftoken', token)
-								} catch (err) {
-									console.log('saving csrf token in localStorage', err)
-								}
-								root.remove()
-								if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
-									origFocus.focus()
-								}
-								resolve(token)
+								const token = await client.Login(loginToken, username.value, password.value, totp.value)
+								loggedIn(token)
 							} catch (err) {
 								console.log('login error', err)
+								if ((err as any).code === 'user:totpRequired') {
+									// Password is valid, ask for the code from the authenticator app.
+									totpLabel.style.display = 'block'
+									totp.required = true
+									fieldset.disabled = false
+									totp.focus()
+									return
+								}
 								window.alert('Error: ' + errmsg(err))
 							} finally {
 								fieldset.disabled = false
@@ -52,14 +72,43 @@ const login = async (reason: string) => {
 						},
 						fieldset=dom.fieldset(
 							dom.h1('Admin'),
+							dom.label(
+								style({display: 'block', marginBottom: '2ex'}),
+								dom.div('Username', style({marginBottom: '.5ex'})),
+								username=dom.input(attr.autocomplete('username'), attr.placeholder('Empty for admin password'), attr.title('Name of an admin user. Leave empty to login with the admin password.')),
+							),
 							dom.label(
 								style({display: 'block', marginBottom: '2ex'}),
 								dom.div('Password', style({marginBottom: '.5ex'})),
 								password=dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required('')),
 							),
+							totpLabel=dom.label(
+								style({display: 'none', marginBottom: '2ex'}),
+								dom.div('Two-factor authentication code', style({marginBottom: '.5ex'})),
+								totp=dom.input(attr.autocomplete('one-time-code')),
+							),
 							dom.div(
 								style({textAlign: 'center'}),
 								dom.submitbutton('Login'),
+								window.PublicKeyCredential ? [
+									' ',
+									dom.clickbutton('Login with passkey', attr.title('Login with a passkey registered for the admin, without password. Or, after entering the password, use the passkey as second factor.'), async function click() {
+										reasonElem.remove()
+										try {
+											fieldset.disabled = true
+											const loginToken = await client.LoginPrep()
+											const opts = await client.PasskeyLoginStart(loginToken)
+											const assertion = await passkeyGet(opts)
+											const token = await client.PasskeyLogin(loginToken, password.value, assertion)
+											loggedIn(token)
+										} catch (err) {
+											console.log('passkey login error', err)
+											window.alert('Error: ' + errmsg(err))
+										} finally {
+											fieldset.disabled = false
+										}
+									}),
+								] : [],
 							),
 						),
 					)
@@ -71,6 +120,57 @@ const login = async (reason: string) => {
 	})
 }
 
+// The WebAuthn browser API uses ArrayBuffers, the API base64 strings.
+const base64ToBuffer = (s: string | null | undefined) => Uint8Array.from(window.atob(s || ''), c => c.charCodeAt(0)).buffer
+const bufferToBase64 = (buf: ArrayBuffer) => window.btoa(String.fromCharCode(...new Uint8Array(buf)))
+
+// passkeyGet lets the user select a passkey in the browser, returning the signed
+// response for the login.
+const passkeyGet = async (opts: api.PasskeyGetOptions): Promise<api.PasskeyAssertion> => {
+	const cred = await window.navigator.credentials.get({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rpId: opts.RPID,
+			timeout: opts.Timeout,
+			userVerification: 'preferred',
+		},
+	}) as PublicKeyCredential | null
+	if (!cred) {
+		throw new Error('no passkey selected')
+	}
+	const resp = cred.response as AuthenticatorAssertionResponse
+	return {
+		CredentialID: bufferToBase64(cred.rawId),
+		ClientDataJSON: bufferToBase64(resp.clientDataJSON),
+		AuthenticatorData: bufferToBase64(resp.authenticatorData),
+		Signature: bufferToBase64(resp.signature),
+	}
+}
+
+// passkeyCreate lets the user create a new passkey in the browser or on a
+// security key, returning the client data and attestation object for the
+// registration.
+const passkeyCreate = async (opts: api.PasskeyCreateOptions): Promise<[string, string]> => {
+	const cred = await window.navigator.credentials.create({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rp: {id: opts.RPID, name: opts.RPName},
+			user: {id: base64ToBuffer(opts.UserID), name: opts.UserName, displayName: opts.UserName},
+			pubKeyCredParams: (opts.Algorithms || []).map(alg => ({type: 'public-key', alg: alg})),
+			excludeCredentials: (opts.ExcludeCredentials || []).map(id => ({type: 'public-key', id: base64ToBuffer(id)})),
+			// Passkeys must be discoverable, logins don't specify credentials.
+			authenticatorSelection: {residentKey: 'required', userVerification: 'preferred'},
+			attestation: 'none',
+			timeout: opts.Timeout,
+		},
+	}) as PublicKeyCredential | null
+	if (!cred) {
+		throw new Error('no passkey created')
+	}
+	const resp = cred.response as AuthenticatorAttestationResponse
+	return [bufferToBase64(resp.clientDataJSON), bufferToBase64(resp.attestationObject)]
+}
+
 // Popup shows kids in a centered div with white background on top of a
 // transparent overlay on top of the window. Clicking the overlay or hitting
 // Escape closes the popup. Scrollbars are automatically added to the div with
@@ -333,10 +433,13 @@ const formatSize = (n: number) => {
 }
 
 const index = async () => {
+	// Domain admins only have access to their domains and accounts.
+	const domainAdmin = adminUser.Role === 'domainadmin'
+	const fullAdmin = adminUser.Role === 'admin'
 	const [domains, queueSize, hooksQueueSize, checkUpdatesEnabled, [accounts, accountsDisabled]] = await Promise.all([
 		client.Domains(),
-		client.QueueSize(),
-		client.HookQueueSize(),
+		domainAdmin ? 0 : client.QueueSize(),
+		domainAdmin ? 0 : client.HookQueueSize(),
 		client.CheckUpdatesEnabled(),
 		client.Accounts(),
 	])
@@ -354,92 +457,108 @@ const index = async () => {
 	return dom.div(
 		crumbs('Mox Admin'),
 		checkUpdatesEnabled ? [] : dom.p(box(yellow, 'Warning: Checking for updates has not been enabled in mox.conf (CheckUpdates: true).', dom.br(), 'Make sure you stay up to date through another mechanism!', dom.br(), 'You have a responsibility to keep the internet-connected software you run up to date and secure!', dom.br(), 'See ', link('https://updates.xmox.nl/changelog'))),
+		adminName ? dom.p('Logged in as admin user ', dom.b(adminName), ', with role ', adminUser.Role, '.') : [],
 		dom.p(
 			dom.a('Accounts', attr.href('#accounts')), dom.br(),
-			dom.a('Queue', attr.href('#queue')), ' ('+queueSize+')', dom.br(),
-			dom.a('Webhook queue', attr.href('#webhookqueue')), ' ('+hooksQueueSize+')', dom.br(),
+			domainAdmin ? [] : [
+				dom.a('Queue', attr.href('#queue')), ' ('+queueSize+')', dom.br(),
+				dom.a('Webhook queue', attr.href('#webhookqueue')), ' ('+hooksQueueSize+')', dom.br(),
+			],
 		),
 		dom.h2('Domains'),
 		(domains || []).length === 0 ? box(red, 'No domains') :
 		dom.ul(
 			(domains || []).map(d => dom.li(dom.a(attr.href('#domains/'+domainName(d.Domain)), domainString(d.Domain)), d.Disabled ? ' (disabled)' : [])),
 		),
-		dom.br(),
-		dom.h2('Add domain'),
-		dom.form(
-			async function submit(e: SubmitEvent) {
-				e.preventDefault()
-				e.stopPropagation()
-				await check(fieldset, client.DomainAdd(disabled.checked, domain.value, account.value, localpart.value))
-				window.location.hash = '#domains/' + domain.value
-			},
-			fieldset=dom.fieldset(
-				dom.label(
-					style({display: 'inline-block'}),
-					dom.span('Domain', attr.title('Domain for incoming/outgoing email to add to mox. Can also be a subdomain of a domain already configured.')),
-					dom.br(),
-					domain=dom.input(attr.required('')),
-				),
-				' ',
-				dom.label(
-					style({display: 'inline-block'}),
-					dom.span('Postmaster/reporting account', attr.title('Account that is considered the owner of this domain. If the account does not yet exist, it will be created and a a localpart is required for the initial email address.')),
-					dom.br(),
-					account=dom.input(attr.required(''), attr.list('accountList')),
-					dom.datalist(attr.id('accountList'), (accounts || []).map(a => dom.option(attr.value(a), a + (accountsDisabled?.includes(a) ? ' (disabled)' : '')))),
-				),
-				' ',
-				dom.label(
-					style({display: 'inline-block'}),
-					dom.span('Localpart (if new account)', attr.title('Must be set if and only if account does not yet exist. A localpart is the part before the "@"-sign of an email address. An account requires an email address, so creating a new account for a domain requires a localpart to form an initial email address.')),
-					dom.br(),
-					localpart=dom.input(),
-				),
-				' ',
-				dom.label(
-					disabled=dom.input(attr.type('checkbox')),
-					' Disabled',
-					attr.title('Disabled domains do fetch new certificates with ACME and do not accept incoming or outgoing messages involving the domain. Accounts and addresses referencing a disabled domain can be created. USeful during/before migrations.'),
-				),
-				' ',
-				dom.submitbutton('Add domain', attr.title('Domain will be added and the config reloaded. Add the required DNS records after adding the domain.')),
-			),
-		),
-		dom.br(),
-		dom.h2('Reports'),
-		dom.div(dom.a('DMARC', attr.href('#dmarc/reports'))),
-		dom.div(dom.a('TLS', attr.href('#tlsrpt/reports'))),
-		dom.br(),
-		dom.h2('Operations'),
-		dom.div(dom.a('MTA-STS policies', attr.href('#mtasts'))),
-		dom.div(dom.a('DMARC evaluations', attr.href('#dmarc/evaluations'))),
-		dom.div(dom.a('TLS connection results', attr.href('#tlsrpt/results'))),
-		dom.div(dom.a('DNSBL', attr.href('#dnsbl'))),
-		dom.div(
-			style({marginTop: '.5ex'}),
+		!fullAdmin ? [] : [
+			dom.br(),
+			dom.h2('Add domain'),
 			dom.form(
 				async function submit(e: SubmitEvent) {
 					e.preventDefault()
 					e.stopPropagation()
-					dom._kids(cidElem)
-					const cid = await check(recvIDFieldset, client.LookupCid(recvID.value))
-					dom._kids(cidElem, cid)
+					await check(fieldset, client.DomainAdd(disabled.checked, domain.value, account.value, localpart.value))
+					window.location.hash = '#domains/' + domain.value
 				},
-				recvIDFieldset=dom.fieldset(
-					dom.label('Received ID', attr.title('The ID in the Received header that was added during incoming delivery.')), ' ',
-					recvID=dom.input(attr.required('')), ' ',
-					dom.submitbutton('Lookup cid', attr.title('Logging about an incoming message includes an attribute "cid", a counter identifying the transaction related to delivery of the message. The ID in the received header is an encrypted cid, which this form decrypts, after which you can look it up in the logging.')), ' ',
-					cidElem=dom.span(),
+				fieldset=dom.fieldset(
+					dom.label(
+						style({display: 'inline-block'}),
+						dom.span('Domain', attr.title('Domain for incoming/outgoing email to add to mox. Can also be a subdomain of a domain already configured.')),
+						dom.br(),
+						domain=dom.input(attr.required('')),
+					),
+					' ',
+					dom.label(
+						style({display: 'inline-block'}),
+						dom.span('Postmaster/reporting account', attr.title('Account that is considered the owner of this domain. If the account does not yet exist, it will be created and a a localpart is required for the initial email address.')),
+						dom.br(),
+						account=dom.input(attr.required(''), attr.list('accountList')),
+						dom.datalist(attr.id('accountList'), (accounts || []).map(a => dom.option(attr.value(a), a + (accountsDisabled?.includes(a) ? ' (disabled)' : '')))),
+					),
+					' ',
+					dom.label(
+						style({display: 'inline-block'}),
+						dom.span('Localpart (if new account)', attr.title('Must be set if and only if account does not yet exist. A localpart is the part before the "@"-sign of an email address. An account requires an email address, so creating a new account for a domain requires a localpart to form an initial email address.')),
+						dom.br(),
+						localpart=dom.input(),
+					),
+					' ',
+					dom.label(
+						disabled=dom.input(attr.type('checkbox')),
+						' Disabled',
+						attr.title('Disabled domains do fetch new certificates with ACME and do not accept incoming or outgoing messages involving the domain. Accounts and addresses referencing a disabled domain can be created. USeful during/before migrations.'),
+					),
+					' ',
+					dom.submitbutton('Add domain', attr.title('Domain will be added and the config reloaded. Add the required DNS records after adding the domain.')),
 				),
 			),
-		),
+		],
+		domainAdmin ? [] : [
+			dom.br(),
+			dom.h2('Reports'),
+			dom.div(dom.a('DMARC', attr.href('#dmarc/reports'))),
+			dom.div(dom.a('TLS', attr.href('#tlsrpt/reports'))),
+			dom.br(),
+			dom.h2('Operations'),
+			dom.div(dom.a('MTA-STS policies', attr.href('#mtasts'))),
+			dom.div(dom.a('DMARC evaluations', attr.href('#dmarc/evaluations'))),
+			dom.div(dom.a('TLS connection results', attr.href('#tlsrpt/results'))),
+			dom.div(dom.a('DNSBL', attr.href('#dnsbl'))),
+			dom.div(dom.a('Sender reputation', attr.href('#senderreputation'))),
+			dom.div(dom.a('SPF analysis', attr.href('#spf'))),
+			dom.div(dom.a('DANE TLSA records', attr.href('#dane'))),
+			dom.div(dom.a('Audit log', attr.href('#auditlog'))),
+			dom.div(dom.a('Signup', attr.href('#signup'))),
+			dom.div(
+				style({marginTop: '.5ex'}),
+				dom.form(
+					async function submit(e: SubmitEvent) {
+						e.preventDefault()
+						e.stopPropagation()
+						dom._kids(cidElem)
+						const cid = await check(recvIDFieldset, client.LookupCid(recvID.value))
+						dom._kids(cidElem, cid)
+					},
+					recvIDFieldset=dom.fieldset(
+						dom.label('Received ID', attr.title('The ID in the Received header that was added during incoming delivery.')), ' ',
+						recvID=dom.input(attr.required('')), ' ',
+						dom.submitbutton('Lookup cid', attr.title('Logging about an incoming message includes an attribute "cid", a counter identifying the transaction related to delivery of the message. The ID in the received header is an encrypted cid, which this form decrypts, after which you can look it up in the logging.')), ' ',
+						cidElem=dom.span(),
+					),
+				),
+			),
+		],
 		// todo: routing, globally, per domain and per account
-		dom.br(),
-		dom.h2('Configuration'),
-		dom.div(dom.a('Routes', attr.href('#routes'))),
-		dom.div(dom.a('Webserver', attr.href('#webserver'))),
-		dom.div(dom.a('Files', attr.href('#config'))),
-		dom.div(dom.a('Log levels', attr.href('#loglevels'))),
+		!fullAdmin ? [] : [
+			dom.br(),
+			dom.h2('Configuration'),
+			dom.div(dom.a('Routes', attr.href('#routes'))),
+			dom.div(dom.a('Webserver', attr.href('#webserver'))),
+			dom.div(dom.a('Files', attr.href('#config'))),
+			dom.div(dom.a('Log levels', attr.href('#loglevels'))),
+			dom.div(dom.a('Admin users', attr.href('#adminusers'))),
+			adminName ? [] : dom.div(dom.a('Two-factor authentication', attr.href('#twofactor'))),
+		],
 		footer(),
 	)
 }
@@ -474,6 +593,235 @@ const config = async () => {
 	)
 }
 
+const twofactor = async () => {
+	const [enabled, passkeys] = await Promise.all([
+		client.AdminTOTPEnabled(),
+		client.AdminPasskeys(),
+	])
+
+	const nowSecs = new Date().getTime()/1000
+
+	let setupBox: HTMLElement
+	let fieldset: HTMLFieldSetElement
+	let code: HTMLInputElement
+	let passkeyFieldset: HTMLFieldSetElement
+	let passkeyName: HTMLInputElement
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'Two-factor authentication',
+		),
+		dom.p('With two-factor authentication, admin logins require a code from an authenticator app in addition to the admin password. Removing the file adminpasswd.totp in the config directory disables two-factor authentication.'),
+		enabled ?
+			dom.p(
+				'Enabled. ',
+				dom.clickbutton('Disable', async function click(e: {target: HTMLButtonElement}) {
+					if (!window.confirm('Are you sure? Admin logins will only require the password.')) {
+						return
+					}
+					await check(e.target, client.AdminTOTPRemove())
+					window.location.reload()
+				}),
+			) :
+			setupBox=dom.div(
+				dom.p('Not enabled.'),
+				dom.clickbutton('Set up two-factor authentication', async function click(e: {target: HTMLButtonElement}) {
+					const setup = await check(e.target, client.AdminTOTPSetupStart())
+					dom._kids(setupBox,
+						dom.p('Scan the QR code with your authenticator app, or enter the secret manually. Then enter the code shown by the app to confirm.'),
+						dom.img(attr.src(setup.QRCodePNG), attr.title(setup.URL)),
+						dom.p('Secret: ', dom.span(dom._class('literal'), setup.Secret)),
+						dom.form(
+							fieldset=dom.fieldset(
+								dom.label(
+									style({display: 'inline-block'}),
+									'Code',
+									dom.br(),
+									code=dom.input(attr.required(''), attr.autocomplete('one-time-code')),
+								),
+								' ',
+								dom.submitbutton('Confirm'),
+							),
+							async function submit(e: SubmitEvent) {
+								e.stopPropagation()
+								e.preventDefault()
+								await check(fieldset, client.AdminTOTPSetupConfirm(code.value))
+								window.alert('Two-factor authentication is now enabled.')
+								window.location.reload()
+							},
+						),
+					)
+				}),
+			),
+		dom.br(),
+		dom.h2('Passkeys'),
+		dom.p('A passkey, stored in your browser, password manager, phone or security key, can be used to login without password. With two-factor authentication enabled, a passkey can also be used as second factor after the password.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Created'),
+					dom.th('Last used'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				passkeys?.length ? [] : dom.tr(dom.td(attr.colspan('4'), 'No passkeys.')),
+				(passkeys || []).map(pk =>
+					dom.tr(
+						dom.td(pk.Name),
+						dom.td(age(pk.Created, false, nowSecs)),
+						dom.td(pk.LastUsed.getTime() > 0 ? age(pk.LastUsed, false, nowSecs) : 'never'),
+						dom.td(
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+								if (!window.confirm('Are you sure? The passkey can no longer be used to login.')) {
+									return
+								}
+								await check(e.target, client.AdminPasskeyRemove(pk.CredentialID))
+								window.location.reload() // todo: only refresh the list
+							}),
+						),
+					),
+				),
+			),
+		),
+		dom.br(),
+		window.PublicKeyCredential ?
+			dom.form(
+				passkeyFieldset=dom.fieldset(
+					dom.label(
+						style({display: 'inline-block'}),
+						'Name',
+						dom.br(),
+						passkeyName=dom.input(attr.required(''), attr.placeholder('Laptop')),
+					),
+					' ',
+					dom.submitbutton('Add passkey'),
+				),
+				async function submit(e: SubmitEvent) {
+					e.stopPropagation()
+					e.preventDefault()
+					await check(passkeyFieldset, (async () => {
+						const opts = await client.AdminPasskeyRegisterStart()
+						const [clientDataJSON, attestationObject] = await passkeyCreate(opts)
+						await client.AdminPasskeyRegister(passkeyName.value, clientDataJSON, attestationObject)
+					})())
+					window.location.reload() // todo: only refresh the list
+				},
+			) :
+			dom.p('Your browser does not support passkeys.'),
+	)
+}
+
+const adminUsers = async () => {
+	const users = await client.AdminUsers()
+
+	const roles = ['admin', 'domainadmin', 'auditor']
+	const parseDomains = (s: string) => s.split(',').map(s => s.trim()).filter(s => !!s)
+
+	let fieldset: HTMLFieldSetElement
+	let name: HTMLInputElement
+	let role: HTMLSelectElement
+	let domains: HTMLInputElement
+	let password: HTMLInputElement
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'Admin users',
+		),
+		dom.p('Admin users login with their username and password. Role "admin" has full access. Role "domainadmin" can manage its domains, with their accounts, addresses and aliases. Role "auditor" has read-only access. Logins with the admin password, without username, always have full access.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Role'),
+					dom.th('Domains', attr.title('Comma-separated, for role domainadmin.')),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				Object.keys(users).length ? [] : dom.tr(dom.td(attr.colspan('4'), 'No admin users.')),
+				Object.keys(users).sort().map(n => {
+					const au = users[n]
+					let userRole: HTMLSelectElement
+					let userDomains: HTMLInputElement
+					return dom.tr(
+						dom.td(n),
+						dom.td(userRole=dom.select(roles.map(r => dom.option(r, r === au.Role ? attr.selected('') : [])))),
+						dom.td(userDomains=dom.input(attr.value((au.Domains || []).join(', ')))),
+						dom.td(
+							dom.clickbutton('Save', async function click(e: {target: HTMLButtonElement}) {
+								await check(e.target, client.AdminUserUpdate(n, userRole.value, parseDomains(userDomains.value)))
+								window.location.reload() // todo: only refresh the list
+							}),
+							' ',
+							dom.clickbutton('Set password', async function click(e: {target: HTMLButtonElement}) {
+								const pw = window.prompt('New password for admin user '+n)
+								if (!pw) {
+									return
+								}
+								await check(e.target, client.AdminUserSetPassword(n, pw))
+								window.alert('Password has been changed.')
+							}),
+							' ',
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+								if (!window.confirm('Are you sure? Sessions of the admin user will no longer be valid.')) {
+									return
+								}
+								await check(e.target, client.AdminUserRemove(n))
+								window.location.reload() // todo: only refresh the list
+							}),
+						),
+					)
+				}),
+			),
+		),
+		dom.br(),
+		dom.h2('Add admin user'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				await check(fieldset, client.AdminUserAdd(name.value, role.value, parseDomains(domains.value), password.value))
+				window.location.reload() // todo: only refresh the list
+			},
+			fieldset=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					'Name',
+					dom.br(),
+					name=dom.input(attr.required('')),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Role',
+					dom.br(),
+					role=dom.select(roles.map(r => dom.option(r))),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Domains', attr.title('Comma-separated, for role domainadmin.')),
+					dom.br(),
+					domains=dom.input(),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Password',
+					dom.br(),
+					password=dom.input(attr.type('password'), attr.autocomplete('new-password'), attr.required('')),
+				),
+				' ',
+				dom.submitbutton('Add admin user'),
+			),
+		),
+	)
+}
+
 const loglevels = async () => {
 	const loglevels = await client.LogLevels()
 
@@ -595,6 +943,11 @@ const accounts = async () => {
 	let domain: HTMLSelectElement
 	let account: HTMLInputElement
 	let accountModified = false
+	let importFieldset: HTMLFieldSetElement
+	let importText: HTMLTextAreaElement
+	let importRequireChange: HTMLInputElement
+	let importDryRun = false
+	let importResults: HTMLElement
 
 	return dom.div(
 		crumbs(
@@ -606,6 +959,7 @@ const accounts = async () => {
 		dom.ul(
 			(accounts || []).map(s => dom.li(dom.a(attr.href('#accounts/l/'+s), s), accountsDisabled?.includes(s) ? ' (disabled)' : '')),
 		),
+		dom.p('See ', dom.a(attr.href('#accounts/storage'), 'storage usage'), ' for accounts using most storage.'),
 		dom.br(),
 		dom.h2('Add account'),
 		dom.form(
@@ -648,6 +1002,42 @@ const accounts = async () => {
 			)
 		),
 		dom.br(),
+		dom.h2('Import accounts'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const results = await check(importFieldset, client.AccountsImport(importText.value, importDryRun, importRequireChange.checked))
+				const failed = (results || []).filter(r => r.Error).length
+				dom._kids(importResults,
+					failed > 0 ? box(red, ''+failed+' of '+(results || []).length+' accounts cannot be added, no accounts were added.') :
+						box(green, importDryRun ? 'All '+(results || []).length+' accounts can be added.' : 'Accounts added. Initial passwords are only shown once, distribute them to the account holders.'),
+					dom.table(
+						dom.thead(dom.tr(dom.th('Account'), dom.th('Address'), dom.th('Password'), dom.th('Error'))),
+						dom.tbody((results || []).map(r => dom.tr(dom.td(r.Name), dom.td(prewrap(r.Address)), dom.td(prewrap(r.Password)), dom.td(r.Error)))),
+					),
+				)
+				if (failed === 0 && !importDryRun) {
+					importText.value = ''
+				}
+			},
+			importFieldset=dom.fieldset(
+				dom.p('Add multiple accounts at once. Either all accounts are added, or none. Each line has comma-separated fields: account name, address, full name, additional addresses (space-separated), quota message size in bytes, password. Fields after the address are optional. Initial passwords are generated for accounts without password. A JSON array of objects with fields Name, Address, FullName, Addresses, QuotaMessageSize and Password is also accepted.'),
+				importText=dom.textarea(attr.required(''), attr.rows('6'), style({width: '60em'}), attr.placeholder('name,address,fullname,addresses,quota,password\njohn,john@example.org,John Doe,jd@example.org,,')),
+				dom.br(),
+				dom.label(
+					importRequireChange=dom.input(attr.type('checkbox')),
+					' Require password change',
+					attr.title('Accounts must set a new password through the account web interface before logging in with other protocols.'),
+				),
+				' ',
+				dom.submitbutton('Check', attr.title('Only check whether the accounts can be added.'), function click() { importDryRun = true }),
+				' ',
+				dom.submitbutton('Import accounts', attr.title('The accounts will be added and the config reloaded.'), function click() { importDryRun = false }),
+			),
+		),
+		importResults=dom.div(),
+		dom.br(),
 		dom.h2('Recent login attempts', attr.title('Login attempts are stored for 30 days. At most 10000 failed login attempts are stored per account to prevent unlimited growth of the database.')),
 		renderLoginAttempts(true, loginAttempts || []),
 		dom.br(),
@@ -686,6 +1076,439 @@ const accountloginattempts = async (accountName: string) => {
 	)
 }
 
+const accountjunkverdicts = async (accountName: string, junkOnly: boolean) => {
+	const verdicts = await client.AccountJunkVerdicts(accountName, junkOnly, 100) || []
+	const nowSecs = new Date().getTime()/1000
+
+	const popupVerdict = (v: api.JunkVerdict) => {
+		const words = (l: api.MessageJunkWord[]) => l.map(w => w.Word + ' (' + w.Score.toFixed(3) + ')').join(', ') || '-'
+		popup(
+			style({maxWidth: '60em'}),
+			dom.h1('Junk analysis'),
+			dom.table(
+				dom.tr(dom.td('Subject'), dom.td(v.Subject)),
+				dom.tr(dom.td('From'), dom.td(v.From)),
+				dom.tr(dom.td('Verdict'), dom.td(v.Verdict.Accept ? 'Accepted' : 'Rejected', ', reason ', v.Verdict.Reason)),
+				dom.tr(dom.td('Mailbox'), dom.td(v.Verdict.Mailbox || '(destination)')),
+				v.Verdict.Classified ? [
+					dom.tr(dom.td('Score'), dom.td(v.Verdict.Score.toFixed(3) + ', threshold ' + v.Verdict.Threshold.toFixed(3) + (v.Verdict.Significant ? '' : ', not significant'))),
+					dom.tr(dom.td('Spam words'), dom.td(words(v.Verdict.SpamWords || []))),
+					dom.tr(dom.td('Ham words'), dom.td(words(v.Verdict.HamWords || []))),
+				] : dom.tr(dom.td('Score'), dom.td('Not classified by junk filter')),
+			),
+			dom.h2('Details'),
+			(v.Verdict.Details || []).length === 0 ? dom.p('No details.') : dom.ul((v.Verdict.Details || []).map(s => dom.li(s))),
+		)
+	}
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			crumblink('Accounts', '#accounts'),
+			crumblink(accountName, '#accounts/l/'+accountName),
+			'Junk analysis verdicts',
+		),
+		dom.h2('Junk analysis verdicts'),
+		dom.p('Verdicts of the junk analysis during delivery of the most recent messages that are still in the account. Only messages delivered over SMTP have a verdict.'),
+		dom.p(
+			junkOnly ? dom.a(attr.href('#accounts/l/'+accountName+'/junkverdicts'), 'Show all messages') : dom.a(attr.href('#accounts/l/'+accountName+'/junkverdicts/junk'), 'Show only rejected and junk messages'),
+		),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Received'),
+					dom.th('Mailbox'),
+					dom.th('From'),
+					dom.th('Subject'),
+					dom.th('Flags', attr.title('Current junk flags of the message, e.g. after being marked by the user.')),
+					dom.th('Verdict'),
+					dom.th('Score'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				verdicts.length === 0 ? dom.tr(dom.td(attr.colspan('8'), 'No messages with verdicts.')) : [],
+				verdicts.map(v =>
+					dom.tr(
+						dom.td(age(v.Received, false, nowSecs)),
+						dom.td(v.Mailbox),
+						dom.td(v.From),
+						dom.td(v.Subject),
+						dom.td(v.Junk ? 'junk' : (v.Notjunk ? 'notjunk' : '')),
+						dom.td(v.Verdict.Accept ? v.Verdict.Reason : box(red, v.Verdict.Reason), v.Verdict.Mailbox ? ' → ' + v.Verdict.Mailbox : ''),
+						dom.td(v.Verdict.Classified ? v.Verdict.Score.toFixed(3) : '-'),
+						dom.td(dom.clickbutton('Details', function click() { popupVerdict(v) })),
+					),
+				),
+			),
+		),
+	)
+}
+
+const accountsStorage = async () => {
+	const limit = 100
+	const usage = await client.StorageUsage(limit)
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			crumblink('Accounts', '#accounts'),
+			'Storage usage',
+		),
+		dom.p('Accounts using most storage, at most ' + limit + '. The quota is the maximum total message size, configured per account, per domain or globally. Accounts can be over their soft limit during a grace period, after which new messages are rejected.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Account'),
+					dom.th('Usage'),
+					dom.th('Quota'),
+					dom.th('% of quota'),
+					dom.th('Soft limit'),
+					dom.th('Grace period end'),
+				),
+			),
+			dom.tbody(
+				(usage || []).length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No accounts.')),
+				(usage || []).map(qs =>
+					dom.tr(
+						dom.td(dom.a(attr.href('#accounts/l/'+qs.Account), qs.Account)),
+						dom.td(style({textAlign: 'right'}), formatSize(qs.MessageSize)),
+						dom.td(style({textAlign: 'right'}), qs.Quota ? formatSize(qs.Quota) : '-'),
+						dom.td(style({textAlign: 'right'}), qs.Quota ? (qs.Percent >= 90 ? box(red, qs.Percent+'%') : qs.Percent >= 80 ? box(yellow, qs.Percent+'%') : qs.Percent+'%') : '-'),
+						dom.td(style({textAlign: 'right'}), qs.SoftLimit ? formatSize(qs.SoftLimit) : '-'),
+						dom.td(qs.GraceEnd.getTime() > 0 ? box(qs.GraceEnd.getTime() < new Date().getTime() ? red : yellow, qs.GraceEnd.toISOString()) : '-'),
+					),
+				),
+			),
+		),
+	)
+}
+
+const auditlog = async () => {
+	const limit = 1000
+	const events = await client.AuditList({Kind: '', Account: '', Actor: '', Start: null, End: null}, limit)
+
+	const kinds = ['', 'login', 'password', 'settings', 'config', 'account', 'export', 'queue']
+
+	let fieldset: HTMLFieldSetElement
+	let kind: HTMLSelectElement
+	let account: HTMLInputElement
+	let actor: HTMLInputElement
+	let eventsElem: HTMLElement
+
+	const render = (l: api.AuditEvent[]) => {
+		const nowSecs = new Date().getTime()/1000
+		return dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Time'),
+					dom.th('Kind'),
+					dom.th('Action'),
+					dom.th('Result'),
+					dom.th('Account'),
+					dom.th('Actor'),
+					dom.th('Protocol'),
+					dom.th('Remote IP'),
+					dom.th('Details'),
+				),
+			),
+			dom.tbody(
+				l.length ? [] : dom.tr(dom.td(attr.colspan('9'), 'No events.')),
+				l.map(e =>
+					dom.tr(
+						dom.td(age(e.Time, false, nowSecs)),
+						dom.td(e.Kind),
+						dom.td(e.Action),
+						dom.td(e.Result === 'ok' ? e.Result : box(red, e.Result)),
+						dom.td(e.Account),
+						dom.td(e.Actor),
+						dom.td(e.Protocol),
+						dom.td(e.RemoteIP),
+						dom.td(e.Details),
+					),
+				),
+			),
+		)
+	}
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'Audit log',
+		),
+		dom.p('The audit log records security-relevant events: logins, password and settings changes, configuration changes, account additions and removals, exports and queue changes. Parameters of changes are not recorded. Events are kept for the retention period configured in mox.conf, by default 365 days. The audit log can be exported with "mox auditlog export".'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const filter: api.AuditFilter = {Kind: kind.value, Account: account.value, Actor: actor.value, Start: null, End: null}
+				const l = await check(fieldset, client.AuditList(filter, limit))
+				dom._kids(eventsElem, render(l || []))
+			},
+			fieldset=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					'Kind',
+					dom.br(),
+					kind=dom.select(kinds.map(k => dom.option(k, attr.value(k)))),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Account',
+					dom.br(),
+					account=dom.input(),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Actor', attr.title('Account name, "(admin)" for logins with the admin password, "(admin:<user>)" for admin users, or "(ctl)" for commands on the command-line.')),
+					dom.br(),
+					actor=dom.input(),
+				),
+				' ',
+				dom.submitbutton('Search'),
+			),
+		),
+		dom.br(),
+		dom.p('At most ' + limit + ' most recent events are shown.'),
+		eventsElem=dom.div(render(events || [])),
+	)
+}
+
+const signup = async () => {
+	const [requests, invites, domains] = await Promise.all([
+		client.SignupRequests(),
+		client.SignupInvites(),
+		client.Domains(),
+	])
+
+	let inviteFieldset: HTMLFieldSetElement
+	let inviteDomain: HTMLSelectElement
+	let inviteMaxUses: HTMLInputElement
+	let inviteDays: HTMLInputElement
+	let inviteNote: HTMLInputElement
+	let inviteLink: HTMLElement
+
+	const signupDomains = (domains || []).filter(d => d.Signup)
+	const pending = (requests || []).filter(r => r.State === api.SignupState.SignupPending)
+	const other = (requests || []).filter(r => r.State !== api.SignupState.SignupPending)
+	const nowSecs = new Date().getTime()/1000
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'Signup',
+		),
+		dom.p('Accounts can be requested through the self-service signup page for domains with signup enabled. The contact email address of a request is verified before an account is created. Signups require an invitation, unless the domain has open signups. Abuse protections, such as limits per IP and a captcha, are configured in mox.conf.'),
+
+		dom.h2('Pending requests'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Created'),
+					dom.th('Address'),
+					dom.th('Full name'),
+					dom.th('Contact email'),
+					dom.th('Invite'),
+					dom.th('Remote IP'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				pending.length ? [] : dom.tr(dom.td(attr.colspan('7'), 'No pending requests.')),
+				pending.map(r => {
+					let fieldset: HTMLFieldSetElement
+					return dom.tr(
+						dom.td(age(r.Created, false, nowSecs)),
+						dom.td(r.Address),
+						dom.td(r.FullName),
+						dom.td(r.ContactEmail),
+						dom.td(r.InviteID ? ''+r.InviteID : ''),
+						dom.td(r.RemoteIP),
+						dom.td(
+							fieldset=dom.fieldset(
+								dom.clickbutton('Approve', attr.title('Create the account and notify the contact email address.'), async function click() {
+									await check(fieldset, client.SignupApprove(r.ID))
+									window.location.reload() // todo: only refresh the list
+								}),
+								' ',
+								dom.clickbutton('Reject', async function click() {
+									const reason = window.prompt('Reason for rejection, optional, included in the notification to the contact email address.')
+									if (reason === null) {
+										return
+									}
+									await check(fieldset, client.SignupReject(r.ID, reason))
+									window.location.reload() // todo: only refresh the list
+								}),
+							),
+						),
+					)
+				}),
+			),
+		),
+		dom.br(),
+
+		dom.h2('Invitations'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('ID'),
+					dom.th('Created'),
+					dom.th('Domain'),
+					dom.th('Expires'),
+					dom.th('Uses'),
+					dom.th('Note'),
+					dom.th('Created by'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				(invites || []).length ? [] : dom.tr(dom.td(attr.colspan('8'), 'No invitations.')),
+				(invites || []).map(si =>
+					dom.tr(
+						dom.td(''+si.ID),
+						dom.td(age(si.Created, false, nowSecs)),
+						dom.td(si.Domain),
+						dom.td(age(si.Expires, true, nowSecs)),
+						dom.td(''+si.Uses+'/'+si.MaxUses),
+						dom.td(si.Note),
+						dom.td(si.CreatedBy),
+						dom.td(
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+								if (!window.confirm('Are you sure? The invitation can no longer be used.')) {
+									return
+								}
+								await check(e.target, client.SignupInviteRemove(si.ID))
+								window.location.reload() // todo: only refresh the list
+							}),
+						),
+					)
+				),
+			),
+		),
+		dom.br(),
+		dom.h2('Add invitation'),
+		signupDomains.length === 0 ? dom.p('Enable signup for a domain below before adding invitations.') : dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const [token, signupPath] = await check(inviteFieldset, client.SignupInviteAdd(inviteDomain.value, parseInt(inviteMaxUses.value), parseInt(inviteDays.value), inviteNote.value))
+				const url = new URL(signupPath + '?invite=' + encodeURIComponent(token), window.location.href).href
+				dom._kids(inviteLink, box(green, 'Invitation added. The link is only shown once, send it to the invitee: ', prewrap(url), ' ', dom.clickbutton('Copy', function click() { navigator.clipboard.writeText(url) })))
+				inviteNote.value = ''
+			},
+			inviteFieldset=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					'Domain',
+					dom.br(),
+					inviteDomain=dom.select(signupDomains.map(d => dom.option(domainName(d.Domain), attr.value(d.Domain.Name)))),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Max uses', attr.title('Number of accounts that can be created with the invitation.')),
+					dom.br(),
+					inviteMaxUses=dom.input(attr.type('number'), attr.required(''), attr.min('1'), attr.value('1'), style({width: '6em'})),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Valid for days',
+					dom.br(),
+					inviteDays=dom.input(attr.type('number'), attr.required(''), attr.min('1'), attr.value('7'), style({width: '6em'})),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Note', attr.title('For admins, e.g. who the invitation is for.')),
+					dom.br(),
+					inviteNote=dom.input(),
+				),
+				' ',
+				dom.submitbutton('Add invitation'),
+			),
+		),
+		inviteLink=dom.div(),
+		dom.br(),
+
+		dom.h2('Domains'),
+		dom.p('Self-service signup is only possible for domains with signup enabled.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Domain'),
+					dom.th('Enabled'),
+					dom.th('Open', attr.title('Allow signups without invitation.')),
+					dom.th('Require approval', attr.title('An admin must approve signups after the contact email address is verified.')),
+					dom.th('Quota', attr.title('Maximum total message size in bytes for new accounts. Zero for the default quota of the domain.')),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				(domains || []).length ? [] : dom.tr(dom.td(attr.colspan('6'), 'No domains.')),
+				(domains || []).map(d => {
+					let fieldset: HTMLFieldSetElement
+					let enabled: HTMLInputElement
+					let open: HTMLInputElement
+					let requireApproval: HTMLInputElement
+					let quota: HTMLInputElement
+					return dom.tr(
+						dom.td(dom.a(attr.href('#domains/'+domainName(d.Domain)), domainString(d.Domain))),
+						dom.td(enabled=dom.input(attr.type('checkbox'), d.Signup ? attr.checked('') : [])),
+						dom.td(open=dom.input(attr.type('checkbox'), d.Signup?.Open ? attr.checked('') : [])),
+						dom.td(requireApproval=dom.input(attr.type('checkbox'), d.Signup?.RequireApproval ? attr.checked('') : [])),
+						dom.td(quota=dom.input(attr.type('number'), attr.min('0'), attr.value(''+(d.Signup?.QuotaMessageSize || 0)), style({width: '10em'}))),
+						dom.td(
+							fieldset=dom.fieldset(
+								dom.clickbutton('Save', async function click() {
+									const ds: api.DomainSignup | null = enabled.checked ? {Open: open.checked, RequireApproval: requireApproval.checked, QuotaMessageSize: parseInt(quota.value) || 0} : null
+									await check(fieldset, client.DomainSignupSave(d.Domain.Name, ds))
+									window.location.reload() // todo: only refresh the list
+								}),
+							),
+						),
+					)
+				}),
+			),
+		),
+		dom.br(),
+
+		dom.h2('Other requests'),
+		dom.p('Unverified requests are removed after 24 hours.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Created'),
+					dom.th('State'),
+					dom.th('Address'),
+					dom.th('Contact email'),
+					dom.th('Remote IP'),
+					dom.th('Decided'),
+					dom.th('Decided by'),
+					dom.th('Reject reason'),
+				),
+			),
+			dom.tbody(
+				other.length ? [] : dom.tr(dom.td(attr.colspan('8'), 'No requests.')),
+				other.map(r =>
+					dom.tr(
+						dom.td(age(r.Created, false, nowSecs)),
+						dom.td(r.State),
+						dom.td(r.Address),
+						dom.td(r.ContactEmail),
+						dom.td(r.RemoteIP),
+						dom.td(r.Decided.getTime() > 0 ? age(r.Decided, false, nowSecs) : ''),
+						dom.td(r.DecidedBy),
+						dom.td(r.RejectReason),
+					)
+				),
+			),
+		),
+	)
+}
+
 const renderLoginAttempts = (accountLinks: boolean, loginAttempts: api.LoginAttempt[]) => {
 	// todo: pagination and search
 
@@ -849,14 +1672,195 @@ const RoutesEditor = (kind: string, transports: { [key: string]: api.Transport }
 	return render()
 }
 
+const OutgoingFooterEditor = (kind: string, footer: api.OutgoingFooter | null | undefined, save: (footer: api.OutgoingFooter | null) => Promise<void>) => {
+	let fieldset: HTMLFieldSetElement
+	let text: HTMLTextAreaElement
+	let html: HTMLTextAreaElement
+
+	return dom.div(
+		dom.h2('Outgoing footer', attr.title('Footer, e.g. a disclaimer, added to the text of outgoing messages during submission, before DKIM signing. Added to text/plain and text/html parts of the message text, not to attachments. Signed or encrypted messages are not changed. A footer configured for an account takes precedence over a footer for the domain of the message From address.')),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const lines = text.value.split('\n').map(s => s.trimEnd())
+				while (lines.length > 0 && !lines[lines.length-1]) {
+					lines.pop()
+				}
+				const nfooter: api.OutgoingFooter | null = lines.length === 0 ? null : {Text: lines, HTML: html.value.split('\n').map(s => s.trim()).filter(s => s).join(' ')}
+				await check(fieldset, save(nfooter))
+			},
+			fieldset=dom.fieldset(
+				dom.label(
+					style({display: 'block', marginBottom: '1ex'}),
+					attr.title('Lines of text added to text/plain parts. Leave empty to remove the '+kind+' footer.'),
+					dom.div('Text'),
+					text=dom.textarea(new String((footer?.Text || []).join('\n')), attr.rows('4'), style({width: '40em'})),
+				),
+				dom.label(
+					style({display: 'block', marginBottom: '1ex'}),
+					attr.title('HTML added to text/html parts, before the closing body tag. If empty, the HTML is generated from the text.'),
+					dom.div('HTML (optional)'),
+					html=dom.textarea(new String(footer?.HTML || ''), attr.rows('3'), style({width: '40em'})),
+				),
+				dom.div(dom.submitbutton('Save')),
+			),
+		),
+	)
+}
+
+const SpamActionsEditor = (kind: string, sa: api.SpamActions | null | undefined, save: (sa: api.SpamActions | null) => Promise<void>) => {
+	let fieldset: HTMLFieldSetElement
+	let tagThreshold: HTMLInputElement
+	let junkThreshold: HTMLInputElement
+	let highAction: HTMLSelectElement
+
+	return dom.div(
+		dom.h2('Spam actions', attr.title('Actions for incoming messages based on the spaminess score of the junk filter: tag messages with a low score, deliver messages with a medium score to the Junk mailbox, and reject or quarantine messages with a score above the junk filter threshold. ' + (kind === 'domain' ? 'Defaults for accounts with this domain as their default domain, used for fields not set for the account.' : 'Fields not set for the account are taken from the default domain of the account.'))),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const nsa: api.SpamActions = {
+					TagThreshold: parseFloat(tagThreshold.value) || 0,
+					JunkThreshold: parseFloat(junkThreshold.value) || 0,
+					HighAction: highAction.value,
+				}
+				await check(fieldset, save(kind === 'domain' && !nsa.TagThreshold && !nsa.JunkThreshold && !nsa.HighAction ? null : nsa))
+			},
+			fieldset=dom.fieldset(
+				dom.div(style({display: 'flex', gap: '1em'}),
+					dom.label(
+						'Tag threshold',
+						attr.title('Spaminess score between 0 and 1 above which accepted messages get X-Mox-Spam-Flag and X-Mox-Spam-Score headers. E.g. 0.5. Empty or 0 for no tagging.'),
+						dom.div(tagThreshold=dom.input(attr.value(sa?.TagThreshold ? ''+sa.TagThreshold : ''))),
+					),
+					dom.label(
+						'Junk threshold',
+						attr.title('Spaminess score between 0 and 1 above which messages are accepted but delivered to the Junk mailbox. Should be below the junk filter threshold. E.g. 0.8. Empty or 0 to not deliver to Junk based on score.'),
+						dom.div(junkThreshold=dom.input(attr.value(sa?.JunkThreshold ? ''+sa.JunkThreshold : ''))),
+					),
+					dom.label(
+						'High action',
+						attr.title('Action for messages with a spaminess score above the junk filter threshold. Reject rejects the message during the SMTP transaction. Quarantine accepts the message, but only stores it in the rejects mailbox of the account.'),
+						dom.div(
+							highAction=dom.select(
+								dom.option('Default', attr.value('')),
+								dom.option('Reject', attr.value('reject'), sa?.HighAction === 'reject' ? attr.selected('') : []),
+								dom.option('Quarantine', attr.value('quarantine'), sa?.HighAction === 'quarantine' ? attr.selected('') : []),
+							),
+						),
+					),
+					dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))),
+				),
+			),
+		),
+	)
+}
+
+const SpamtrapsEditor = (localparts: string[], save: (localparts: string[]) => Promise<void>) => {
+	let fieldset: HTMLFieldSetElement
+	let text: HTMLTextAreaElement
+
+	return dom.div(
+		dom.h2('Spamtraps', attr.title('Messages sent to spamtrap addresses are accepted but never delivered. The server-wide reputation of the sending IP and validated message From domain is lowered, and the message is trained as spam in the shared junk filter of the domain, if configured. Spamtrap addresses should never be given out, only be found by spammers, e.g. in hidden links on web pages. They must not be configured as address of an account or alias.')),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				await check(fieldset, save(text.value.split('\n').map(s => s.trim()).filter(s => s)))
+			},
+			fieldset=dom.fieldset(
+				dom.label(
+					style({display: 'block', marginBottom: '1ex'}),
+					attr.title('Localparts of spamtrap addresses in this domain, the part before the "@"-sign, one per line.'),
+					dom.div('Localparts'),
+					text=dom.textarea(new String(localparts.join('\n')), attr.rows('4'), style({width: '20em'})),
+				),
+				dom.div(dom.submitbutton('Save')),
+			),
+		),
+	)
+}
+
+const MessageTemplatesEditor = (templates: api.MessageTemplate[], save: (templates: api.MessageTemplate[]) => Promise<void>) => {
+	let fieldset: HTMLFieldSetElement
+	let templatesElem: HTMLElement
+
+	interface TemplateView {
+		root: HTMLElement
+		name: HTMLInputElement
+		subject: HTMLInputElement
+		text: HTMLTextAreaElement
+	}
+	let views: TemplateView[] = []
+
+	const templateView = (t: api.MessageTemplate) => {
+		let name: HTMLInputElement
+		let subject: HTMLInputElement
+		let text: HTMLTextAreaElement
+		const root = dom.div(
+			style({marginBottom: '1ex'}),
+			dom.div(
+				dom.label('Name ', name=dom.input(attr.value(t.Name), attr.required(''))), ' ',
+				dom.label('Subject ', subject=dom.input(attr.value(t.Subject), style({width: '20em'}))), ' ',
+				dom.clickbutton('Remove', function click() {
+					views = views.filter(v => v !== tv)
+					root.remove()
+				}),
+			),
+			text=dom.textarea(new String((t.Text || []).join('\n')), attr.rows('4'), style({width: '40em'})),
+		)
+		const tv: TemplateView = {root: root, name: name, subject: subject, text: text}
+		return tv
+	}
+	views = templates.map(t => templateView(t))
+
+	return dom.div(
+		dom.h2('Message templates', attr.title('Templates for composing messages in the webmail, e.g. canned responses, available to all accounts with an address in this domain. The subject and text can contain placeholders that are replaced when the template is used: {{recipient.name}}, {{recipient.firstname}}, {{recipient.email}}, {{sender.name}}, {{sender.email}} and {{date}}.')),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const l: api.MessageTemplate[] = views.map(v => {
+					const lines = v.text.value.split('\n').map(s => s.trimEnd())
+					while (lines.length > 0 && !lines[lines.length-1]) {
+						lines.pop()
+					}
+					return {Name: v.name.value.trim(), Subject: v.subject.value, Text: lines}
+				})
+				await check(fieldset, save(l))
+			},
+			fieldset=dom.fieldset(
+				templatesElem=dom.div(views.map(v => v.root)),
+				dom.div(
+					style({marginBottom: '1ex'}),
+					dom.clickbutton('Add template', function click() {
+						const v = templateView({Name: '', Subject: '', Text: []})
+						views.push(v)
+						templatesElem.appendChild(v.root)
+						v.name.focus()
+					}),
+				),
+				dom.div(dom.submitbutton('Save')),
+			),
+		),
+	)
+}
+
 const account = async (name: string) => {
-	const [[config, diskUsage], domains, transports, tlspubkeys, loginAttempts] = await Promise.all([
+	const [[config, diskUsage], domains, transports, tlspubkeys, loginAttempts, twoFactorEnabled, passkeys, sendSuspension, storage] = await Promise.all([
 		client.Account(name),
 		client.Domains(),
 		client.Transports(),
 		client.TLSPublicKeys(name),
 		client.LoginAttempts(name, 10),
+		client.AccountTwoFactorEnabled(name),
+		client.AccountPasskeys(name),
+		client.AccountSendSuspension(name),
+		client.AccountStorage(name),
 	])
+	const junkStats = config.JunkFilter ? await client.AccountJunkFilterStats(name) : null
 
 	// todo: show suppression list, and buttons to add/remove entries.
 
@@ -877,6 +1881,19 @@ const account = async (name: string) => {
 	let password: HTMLInputElement
 	let passwordHint: HTMLElement
 
+	let fieldsetPasswordPolicy: HTMLFieldSetElement
+	let fieldsetTwoFactor: HTMLFieldSetElement
+	let requireTwoFactor: HTMLInputElement
+	let policyMinLength: HTMLInputElement
+	let policyMinEntropy: HTMLInputElement
+	let policyMaxAge: HTMLInputElement
+	let policyLockoutFailures: HTMLInputElement
+	let policyLockoutPeriod: HTMLInputElement
+
+	let fieldsetImpersonate: HTMLFieldSetElement
+	let impersonateReadOnly: HTMLInputElement
+	let impersonateMinutes: HTMLInputElement
+
 	const xparseSize = (s: string) => {
 		s = s.toLowerCase()
 		let mult = 1
@@ -906,6 +1923,16 @@ const account = async (name: string) => {
 			name,
 		),
 		config.LoginDisabled ? dom.p(box(yellow, 'Warning: Login for this account is disabled with message: '+config.LoginDisabled)) : [],
+		sendSuspension ? dom.p(box(red,
+			'Sending is suspended for this account since '+sendSuspension.Suspended.toLocaleString()+', after an anomaly in outgoing messages: '+sendSuspension.Reason+'. Submissions are rejected until sending is resumed. ',
+			dom.clickbutton('Resume sending', attr.title('Accept submissions for this account again. Investigate whether the account was compromised first, e.g. by checking the recent outgoing messages and login attempts.'), async function click(e: {target: HTMLButtonElement}) {
+				if (!window.confirm('Are you sure you want to resume sending for this account?')) {
+					return
+				}
+				await check(e.target, client.AccountSendResume(name))
+				window.location.reload() // todo: only update the warning
+			}),
+		)) : [],
 		dom.h2('Addresses'),
 		dom.table(
 			dom.thead(
@@ -932,7 +1959,7 @@ const account = async (name: string) => {
 					return dom.tr(
 						dom.td(v),
 						dom.td(
-							dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 								e.preventDefault()
 								const aliases = (config.Aliases || []).filter(aa => aa.SubscriptionAddress === k).map(aa => aa.Alias.LocalpartStr+"@"+domainName(aa.Alias.Domain))
 								const aliasmsg = aliases.length > 0 ? ' Address will be removed from alias(es): '+aliases.join(', ') : ''
@@ -998,7 +2025,7 @@ const account = async (name: string) => {
 					dom.td(a.Alias.AllowMsgFrom ? 'Yes' : 'No'),
 					dom.td(a.Alias.ListMembers ? 'Yes' : 'No'),
 					dom.td(
-						dom.clickbutton('Remove', async function click(e: MouseEvent) {
+						dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 							await check(e.target! as HTMLButtonElement, client.AliasAddressesRemove(a.Alias.LocalpartStr, domainName(a.Alias.Domain), [a.SubscriptionAddress]))
 							window.location.reload() // todo: reload less
 						}),
@@ -1029,6 +2056,7 @@ const account = async (name: string) => {
 					dom.br(),
 					quotaMessageSize=dom.input(attr.value(formatQuotaSize(config.QuotaMessageSize))),
 					' Current usage is ', formatQuotaSize(Math.floor(diskUsage/(1024*1024))*1024*1024), '.',
+					storage.Compressed > 0 ? ' Stored on disk with compression: '+formatQuotaSize(Math.floor(storage.StoredSize/(1024*1024))*1024*1024)+', '+storage.Compressed+' of '+storage.Messages+' messages compressed.' : [],
 				),
 				dom.div(
 					style({display: 'block', marginBottom: '.5ex'}),
@@ -1097,6 +2125,132 @@ const account = async (name: string) => {
 			},
 		),
 		dom.br(),
+		dom.clickbutton('Require password change', attr.title('Mark the current password as expired. Login attempts on all protocols except the account web interface are rejected until the account sets a new password. Sessions are logged out.'), async function click(e: {target: HTMLButtonElement}) {
+			if (!window.confirm('Are you sure? The account can only login to the account web interface to set a new password.')) {
+				return
+			}
+			await check(e.target, client.PasswordRequireChange(name))
+			window.alert('Password change is now required.')
+		}),
+		dom.br(),
+		dom.h2('Password policy'),
+		dom.form(
+			fieldsetPasswordPolicy=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Minimum length', attr.title('Minimum number of characters for passwords the account sets through the account web interface. Passwords are always at least 8 characters.')),
+					dom.br(),
+					policyMinLength=dom.input(attr.type('number'), attr.min('0'), attr.value(config.PasswordPolicy?.MinLength ? ''+config.PasswordPolicy.MinLength : '')),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Minimum strength (bits)', attr.title('Minimum estimated strength in bits of passwords the account sets, based on the length and the kinds of characters. E.g. 50. Randomly generated passwords have around 75 bits.')),
+					dom.br(),
+					policyMinEntropy=dom.input(attr.type('number'), attr.min('0'), attr.value(config.PasswordPolicy?.MinEntropy ? ''+config.PasswordPolicy.MinEntropy : '')),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Maximum age', attr.title('If set, passwords expire after this period, and login attempts with an expired password are rejected on all protocols except the account web interface. E.g. 13w or 90d.')),
+					dom.br(),
+					policyMaxAge=dom.input(attr.value(formatDuration(config.PasswordPolicy?.MaxAge || 0))),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Lockout after failures', attr.title('If set, after this many consecutive failed login attempts for the account, on any protocol, login attempts are rejected during the lockout period, even with the correct password.')),
+					dom.br(),
+					policyLockoutFailures=dom.input(attr.type('number'), attr.min('0'), attr.value(config.PasswordPolicy?.LockoutFailures ? ''+config.PasswordPolicy.LockoutFailures : '')),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					dom.span('Lockout period', attr.title('Period during which login attempts are rejected after too many failed attempts. Default 15m.')),
+					dom.br(),
+					policyLockoutPeriod=dom.input(attr.value(formatDuration(config.PasswordPolicy?.LockoutPeriod || 0))),
+				),
+				' ',
+				dom.submitbutton('Save'),
+			),
+			async function submit(e: SubmitEvent) {
+				e.stopPropagation()
+				e.preventDefault()
+				await check(fieldsetPasswordPolicy, (async () => {
+					const policy: api.PasswordPolicy = {
+						MinLength: parseInt(policyMinLength.value) || 0,
+						MinEntropy: parseInt(policyMinEntropy.value) || 0,
+						MaxAge: parseDuration(policyMaxAge.value),
+						LockoutFailures: parseInt(policyLockoutFailures.value) || 0,
+						LockoutPeriod: parseDuration(policyLockoutPeriod.value),
+					}
+					const empty = !policy.MinLength && !policy.MinEntropy && !policy.MaxAge && !policy.LockoutFailures && !policy.LockoutPeriod
+					await client.AccountPasswordPolicySave(name, empty ? null : policy)
+				})())
+			},
+		),
+		dom.br(),
+		dom.h2('Two-factor authentication'),
+		dom.p(
+			twoFactorEnabled ? 'Enabled by the account. ' : 'Not enabled by the account. ',
+			twoFactorEnabled ? dom.clickbutton('Reset two-factor authentication', attr.title('Remove the two-factor authentication configuration of the account, e.g. after the account lost their authenticator app. App passwords are kept.'), async function click(e: {target: HTMLButtonElement}) {
+				if (!window.confirm('Are you sure? The account can login to the web interfaces with just the password.')) {
+					return
+				}
+				await check(e.target, client.AccountTOTPRemove(name))
+				window.location.reload()
+			}) : [],
+		),
+		dom.form(
+			fieldsetTwoFactor=dom.fieldset(
+				dom.label(
+					attr.title('If set, the account must set up two-factor authentication. Until then, it can only login to the account web interface. IMAP/SMTP and other protocols require app passwords. Two-factor authentication can also be required for all accounts of a domain.'),
+					requireTwoFactor=dom.input(attr.type('checkbox'), config.RequireTwoFactor ? attr.checked('') : []),
+					' Require two-factor authentication',
+				),
+				' ',
+				dom.submitbutton('Save'),
+			),
+			async function submit(e: SubmitEvent) {
+				e.stopPropagation()
+				e.preventDefault()
+				await check(fieldsetTwoFactor, client.AccountRequireTwoFactorSave(name, requireTwoFactor.checked))
+			},
+		),
+		dom.br(),
+		dom.h2('Passkeys', attr.title('Passkeys are registered by the account in the account web interface. They can be used to login to the web interfaces without password, or as second factor.')),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Name'),
+					dom.th('Login address'),
+					dom.th('Created'),
+					dom.th('Last used'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				passkeys?.length ? [] : dom.tr(dom.td(attr.colspan('5'), 'None')),
+				(passkeys || []).map(pk =>
+					dom.tr(
+						dom.td(pk.Name),
+						dom.td(pk.LoginAddress),
+						dom.td(age(pk.Created, false, 0)),
+						dom.td(pk.LastUsed.getTime() > 0 ? age(pk.LastUsed, false, 0) : 'never'),
+						dom.td(
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+								if (!window.confirm('Are you sure? The account can no longer use the passkey to login.')) {
+									return
+								}
+								await check(e.target, client.AccountPasskeyRemove(name, pk.CredentialID))
+								window.location.reload() // todo: only refresh the list
+							}),
+						),
+					),
+				),
+			),
+		),
+		dom.br(),
 		dom.h2('TLS public keys', attr.title('For TLS client authentication with certificates, for IMAP and/or submission (SMTP). Only the public key of the certificate is used during TLS authentication, to identify this account. Names, expiration or constraints are not verified.')),
 		dom.table(
 			dom.thead(
@@ -1126,6 +2280,55 @@ const account = async (name: string) => {
 		dom.br(),
 		RoutesEditor('account-specific', transports, config.Routes || [], async (routes: api.Route[]) => await client.AccountRoutesSave(name, routes)),
 		dom.br(),
+		OutgoingFooterEditor('account', config.OutgoingFooter, async (footer: api.OutgoingFooter | null) => await client.AccountOutgoingFooterSave(name, footer)),
+		dom.br(),
+		dom.h2('Junk filter', attr.title('The junk filter is trained with messages that are marked as junk or not junk, e.g. by moving them to or out of the Junk mailbox through IMAP, webmail or the webapi.')),
+		junkStats ? [
+			dom.p('Trained with ', ''+junkStats.Account.Hams, ' ham and ', ''+junkStats.Account.Spams, ' spam messages, ', ''+junkStats.Account.Words, ' words.'),
+			junkStats.Shared ? dom.p('Shared junk filter of the domain of the account: trained with ', ''+junkStats.Shared.Hams, ' ham and ', ''+junkStats.Shared.Spams, ' spam messages, ', ''+junkStats.Shared.Words, ' words.') : [],
+			dom.clickbutton('Retrain from scratch', attr.title('Remove the junk filter of the account and train a new junk filter with all messages marked as junk or not junk. The account is locked while retraining. The shared junk filter of the domain is not changed.'), async function click(e: {target: HTMLButtonElement}) {
+				if (!window.confirm('Are you sure? Retraining can take a while for large accounts.')) {
+					return
+				}
+				const [total, trained] = await check(e.target, client.AccountJunkFilterRetrain(name))
+				window.alert('Trained ' + trained + ' of ' + total + ' messages.')
+				window.location.reload()
+			}),
+		] : dom.p('No junk filter configured for this account.'),
+		dom.p('See ', dom.a(attr.href('#accounts/l/'+name+'/junkverdicts'), 'junk analysis verdicts'), ' of messages delivered to this account.'),
+		dom.br(),
+		config.JunkFilter ? [
+			SpamActionsEditor('account', config.JunkFilter, async (sa: api.SpamActions | null) => await client.AccountSpamActionsSave(name, sa || {TagThreshold: 0, JunkThreshold: 0, HighAction: ''})),
+			dom.br(),
+		] : [],
+		dom.h2('Impersonate', attr.title('Open webmail as this account, e.g. for troubleshooting, without needing the password. The webmail interface shows a banner during the session. The start of the session and all actions in it are recorded in the audit log.')),
+		dom.form(
+			fieldsetImpersonate=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					impersonateReadOnly=dom.input(attr.type('checkbox'), attr.checked('')),
+					' View only',
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Duration in minutes ',
+					impersonateMinutes=dom.input(attr.type('number'), attr.min('1'), attr.max('480'), attr.value('30'), attr.required('')),
+				),
+				' ',
+				dom.submitbutton('Open webmail'),
+			),
+			async function submit(e: SubmitEvent) {
+				e.stopPropagation()
+				e.preventDefault()
+				const [token, webmailPath] = await check(fieldsetImpersonate, client.AccountImpersonate(name, impersonateReadOnly.checked, parseInt(impersonateMinutes.value)))
+				const url = webmailPath + 'impersonate?token=' + encodeURIComponent(token)
+				if (!window.open(url, '_blank')) {
+					window.location.href = url
+				}
+			},
+		),
+		dom.br(),
 
 		dom.h2('Danger'),
 		dom.div(
@@ -1214,6 +2417,74 @@ const formatDuration = (v: number, goDuration?: boolean) => {
 	return format(second, 's')
 }
 
+const popupDKIMRotation = (d: string, st: api.DKIMRotationStatus) => {
+	const fmtTime = (t: Date) => t.getTime() <= 0 ? '-' : t.toISOString()
+	const tasks = st.Tasks || []
+	const history = st.History || []
+	const close = popup(
+		style({maxWidth: '80em'}),
+		dom.h1('DKIM key rotation'),
+		!st.Config ? dom.p('Automatic key rotation is not configured for this domain. Configure "Rotation" in the DKIM section of the domain in domains.conf, or "DKIMRotation" in its DNSProvisioning section.') : [
+			dom.p('Every ' + formatDuration(st.Config.Interval || 90*24*3600*1000*1000*1000) + ', new DKIM keys are added. ', st.Provisioned ? 'Their DNS records are published through DNS provisioning. ' : 'Their DNS records must be added manually, see tasks below. ', 'Once their DNS records are found, signing switches to the new keys. The old keys are removed after a retain period.'),
+			dom.table(
+				dom.tr(dom.td('Previous rotation'), dom.td(fmtTime(st.Rotated))),
+				dom.tr(dom.td('Rotation in progress'), dom.td(st.Added.getTime() <= 0 ? 'No' : 'Replacing ' + (st.Old || []).join(', ') + ' with ' + (st.New || []).join(', ') + ', added ' + fmtTime(st.Added) + (st.Switched.getTime() <= 0 ? '' : ', switched ' + fmtTime(st.Switched)))),
+				dom.tr(dom.td('Next stage'), dom.td(st.NextStage ? st.NextStage + ', due ' + fmtTime(st.Next) : '-')),
+			),
+		],
+		tasks.length === 0 ? [] : [
+			dom.h2('Tasks'),
+			dom.p('Make these changes to DNS. Signing only switches to new keys after their records are found in DNS.'),
+			dom.table(
+				dom.thead(dom.tr(dom.th('Action'), dom.th('Name'), dom.th('Type'), dom.th('Value'))),
+				dom.tbody(
+					tasks.map(t => dom.tr(dom.td(t.Action), dom.td(t.Name), dom.td('TXT'), dom.td(style({wordBreak: 'break-all'}), t.Value))),
+				),
+			),
+			!tasks.find(t => t.Action === 'remove') ? [] : dom.div(
+				style({marginTop: '1ex'}),
+				dom.clickbutton('Mark old records as removed', async function click(e: MouseEvent) {
+					await check(e.target! as HTMLButtonElement, client.DomainDKIMRecordsRemoved(d))
+					close()
+				}),
+			),
+		],
+		dom.h2('History'),
+		dom.table(
+			dom.thead(dom.tr(dom.th('Time'), dom.th('Event'), dom.th('Selectors'), dom.th('Details'))),
+			dom.tbody(
+				history.length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [],
+				history.map(e => dom.tr(dom.td(fmtTime(e.Time)), dom.td(e.Kind), dom.td((e.Selectors || []).join(', ')), dom.td(style({whiteSpace: 'pre-wrap', wordBreak: 'break-all'}), e.Text))),
+			),
+		),
+	)
+}
+
+const popupMTASTSRollout = (d: string, domainConfig: api.Domain, r: api.MTASTSRollout) => {
+	const close = popup(
+		dom.h1('MTA-STS staged rollout'),
+		dom.p('Start with a policy in mode "testing". Remote mail servers report failures through TLS reporting, but still deliver when TLS cannot be verified. Once TLS reports for at least a week show no failures, the policy can be enforced. Promoting sets a new policy ID.'),
+		dom.p('Current policy: ', r.Policy ? r.Policy.Mode + ', max age ' + formatDuration(r.Policy.MaxAge) + ', policy ID ' + r.Policy.PolicyID : 'none'),
+		dom.table(
+			dom.tr(dom.td('Days with reports in mode testing'), dom.td(style({textAlign: 'right'}), '' + r.TestingDays)),
+			dom.tr(dom.td('Successful sessions'), dom.td(style({textAlign: 'right'}), '' + r.TestingSuccess)),
+			dom.tr(dom.td('Failed sessions'), dom.td(style({textAlign: 'right'}), '' + r.TestingFailure)),
+			dom.tr(dom.td('Failed sessions, past week'), dom.td(style({textAlign: 'right'}), r.RecentFailure > 0 ? style({backgroundColor: red}) : [], '' + r.RecentFailure)),
+			Object.entries(r.FailureTypes || {}).map(t => dom.tr(dom.td('- ' + t[0]), dom.td(style({textAlign: 'right'}), '' + t[1]))),
+			dom.tr(dom.td('Most recent report period end'), dom.td(r.LastReportPeriod.getTime() <= 0 ? '-' : r.LastReportPeriod.toISOString())),
+		),
+		dom.ul((r.Reasons || []).map(s => dom.li(s))),
+		!r.NextMode ? [] : dom.div(
+			dom.clickbutton('Promote to ' + r.NextMode, r.Ready ? [] : attr.disabled(''), async function click(e: MouseEvent) {
+				const policyID = await check(e.target! as HTMLButtonElement, client.DomainMTASTSPromote(d))
+				window.alert('Policy promoted to mode ' + r.NextMode + ' with policy ID ' + policyID + '. ' + (domainConfig.DNSProvisioning ? 'The MTA-STS DNS record has been updated.' : "Don't forget to update the MTA-STS DNS record with the new policy ID, see suggested DNS records."))
+				close()
+				window.location.reload() // todo: reload just the mtasts policy
+			}),
+		),
+	)
+}
+
 const domain = async (d: string) => {
 	const end = new Date()
 	const start = new Date(new Date().getTime() - 30*24*3600*1000)
@@ -1244,6 +2515,9 @@ const domain = async (d: string) => {
 	let clientSettingsDomainFieldset: HTMLFieldSetElement
 	let clientSettingsDomain: HTMLInputElement
 
+	let requireTwoFactorFieldset: HTMLFieldSetElement
+	let requireTwoFactor: HTMLInputElement
+
 	let localpartFieldset: HTMLFieldSetElement
 	let localpartCaseSensitive: HTMLInputElement
 
@@ -1400,6 +2674,7 @@ const domain = async (d: string) => {
 		dom.ul(
 			dom.li(dom.a('Required DNS records', attr.href('#domains/' + d + '/dnsrecords'))),
 			dom.li(dom.a('Check current actual DNS records and domain configuration', attr.href('#domains/' + d + '/dnscheck'))),
+			dom.li(dom.a('Readiness checklist, for setting up a new domain', attr.href('#domains/' + d + '/readiness'))),
 		),
 		dom.br(),
 
@@ -1446,7 +2721,7 @@ const domain = async (d: string) => {
 						dom.td(prewrap(t[0]) || '(catchall)'),
 						dom.td(dom.a(t[1], attr.href('#accounts/l/'+t[1]))),
 						dom.td(
-							dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 								e.preventDefault()
 								if (!window.confirm('Are you sure you want to remove this address? If it is a member of an alias, it will be removed from the alias.')) {
 									return
@@ -1554,6 +2829,14 @@ const domain = async (d: string) => {
 
 		RoutesEditor('domain-specific', transports, domainConfig.Routes || [], async (routes: api.Route[]) => await client.DomainRoutesSave(d, routes)),
 		dom.br(),
+		OutgoingFooterEditor('domain', domainConfig.OutgoingFooter, async (footer: api.OutgoingFooter | null) => await client.DomainOutgoingFooterSave(d, footer)),
+		dom.br(),
+		SpamActionsEditor('domain', domainConfig.SpamActions, async (sa: api.SpamActions | null) => await client.DomainSpamActionsSave(d, sa)),
+		dom.br(),
+		SpamtrapsEditor(domainConfig.Spamtraps || [], async (localparts: string[]) => await client.DomainSpamtrapsSave(d, localparts)),
+		dom.br(),
+		MessageTemplatesEditor(domainConfig.MessageTemplates || [], async (templates: api.MessageTemplate[]) => await client.DomainMessageTemplatesSave(d, templates)),
+		dom.br(),
 
 		dom.h2('Settings'),
 		dom.form(
@@ -1589,6 +2872,23 @@ const domain = async (d: string) => {
 				dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))),
 			),
 		),
+		dom.form(
+			style({marginTop: '1ex'}),
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				await check(requireTwoFactorFieldset, client.DomainRequireTwoFactorSave(d, requireTwoFactor.checked))
+			},
+			requireTwoFactorFieldset=dom.fieldset(
+				dom.label(
+					attr.title('If set, accounts with this domain as their default domain must set up two-factor authentication. Until then, they can only login to the account web interface. IMAP/SMTP and other protocols require app passwords.'),
+					requireTwoFactor=dom.input(attr.type('checkbox'), domainConfig.RequireTwoFactor ? attr.checked('') : []),
+					' Require two-factor authentication for accounts',
+				),
+				' ',
+				dom.submitbutton('Save'),
+			),
+		),
 		(() => {
 			interface SeparatorView {
 				root: HTMLElement
@@ -1780,7 +3080,19 @@ const domain = async (d: string) => {
 					mode = mtastsMode.value as api.Mode
 					maxAge = parseDuration(mtastsMaxAge.value)
 					mx = mtastsMX.value ? mtastsMX.value.split('\n') : []
-					if (domainConfig.MTASTS?.PolicyID === mtastsPolicyID.value && !window.confirm('Are you sure you want to save the policy without updating the policy ID? Remote servers may hold on to the old cached policies. Policy IDs should be changed when the policy is changed. Remember to first update the policy here, then publish the new policy ID in DNS.')) {
+					if (domainConfig.MTASTS?.PolicyID === mtastsPolicyID.value) {
+						// Policy ID not changed by user, let the server set a new ID if the policy changed.
+						const policyID = await check(mtastsFieldset, client.DomainMTASTSPolicySave(d, mode, maxAge, mx))
+						if (policyID !== domainConfig.MTASTS?.PolicyID) {
+							window.alert('Policy saved with new policy ID ' + policyID + '. ' + (domainConfig.DNSProvisioning ? 'The MTA-STS DNS record has been updated.' : "Don't forget to update the MTA-STS DNS record with the new policy ID, see suggested DNS records."))
+						}
+						mtastsPolicyID.value = policyID
+						domainConfig.MTASTS = {
+							PolicyID: policyID,
+							Mode: mode,
+							MaxAge: maxAge,
+							MX: mx,
+						}
 						return
 					}
 				}
@@ -1843,6 +3155,13 @@ const domain = async (d: string) => {
 				dom.div(dom.span('\u00a0'), dom.div(dom.submitbutton('Save'))),
 			),
 		),
+		dom.div(
+			style({marginTop: '1ex'}),
+			dom.clickbutton('Staged rollout...', attr.title('Evaluate TLS reports about the policy, and promote the policy from mode testing to enforce when no recent failures are reported.'), async function click(e: MouseEvent) {
+				const r = await check(e.target! as HTMLButtonElement, client.DomainMTASTSRollout(d))
+				popupMTASTSRollout(d, domainConfig, r)
+			}),
+		),
 		dom.br(),
 
 		dom.h2('DKIM', attr.title('With DKIM signing, a domain is taking responsibility for (content of) emails it sends, letting receiving mail servers build up a (hopefully positive) reputation of the domain, which can help with mail delivery.')),
@@ -1926,7 +3245,7 @@ const domain = async (d: string) => {
 										}),
 									),
 									dom.td(lifetime=dom.input(attr.value(sel.Expiration))),
-									dom.td(dom.clickbutton('Remove', async function click(e: MouseEvent) {
+									dom.td(dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 										if (!window.confirm('Are you sure you want to remove this selector? It is removed immediately, after which the page is reloaded, losing unsaved changes.')) {
 											return
 										}
@@ -1967,6 +3286,11 @@ const domain = async (d: string) => {
 									dom.clickbutton('Add key/selector', function click() {
 										popupDKIMAdd()
 									}),
+									' ',
+									dom.clickbutton('Key rotation...', attr.title('Show the state and history of automatic DKIM key rotation, and DNS records to add or remove for domains without DNS provisioning.'), async function click(e: MouseEvent) {
+										const st = await check(e.target! as HTMLButtonElement, client.DomainDKIMRotation(d))
+										popupDKIMRotation(d, st)
+									}),
 								),
 							),
 						),
@@ -2015,17 +3339,38 @@ const domainAlias = async (d: string, aliasLocalpart: string) => {
 	if (!alias) {
 		throw new Error('alias not found')
 	}
+	const [subscribers, archive] = await Promise.all([
+		alias.List ? client.ListSubscribers(aliasLocalpart, d) : Promise.resolve([]),
+		alias.List?.Archive ? client.ListArchive(aliasLocalpart, d) : Promise.resolve([]),
+	])
 
 	let aliasFieldset: HTMLFieldSetElement
 	let postPublic: HTMLInputElement
 	let listMembers: HTMLInputElement
 	let allowMsgFrom: HTMLInputElement
 
+	let listFieldset: HTMLFieldSetElement
+	let postPolicy: HTMLSelectElement
+	let listHeaders: HTMLInputElement
+	let owners: HTMLTextAreaElement
+
 	let addFieldset: HTMLFieldSetElement
 	let addAddress: HTMLTextAreaElement
 
+	let mlistFieldset: HTMLFieldSetElement
+	let mlistEnabled: HTMLInputElement
+	let mlistSubscribe: HTMLInputElement
+	let mlistArchive: HTMLInputElement
+	let mlistBounceLimit: HTMLInputElement
+
+	let subscriberFieldset: HTMLFieldSetElement
+	let subscriberAddress: HTMLInputElement
+	let subscriberDigest: HTMLInputElement
+
 	let delFieldset: HTMLFieldSetElement
 
+	const nowSecs = new Date().getTime()/1000
+
 	return dom.div(
 		crumbs(
 			crumblink('Mox Admin', '#'),
@@ -2060,6 +3405,38 @@ const domainAlias = async (d: string, aliasLocalpart: string) => {
 		),
 		dom.br(),
 
+		dom.h2('Distribution list'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				await check(listFieldset, client.AliasListSave(aliasLocalpart, d, postPolicy.value, listHeaders.checked, owners.value.split('\n').map(s => s.trim()).filter(s => s)))
+			},
+			listFieldset=dom.fieldset(
+				style({display: 'flex', flexDirection: 'column', gap: '.5ex'}),
+				dom.label(
+					'Allowed senders',
+					attr.title('Overrides the public setting above if set. For moderated lists, messages from others than the owners are only delivered to the owners, who can resend approved messages to the list.'),
+					dom.div(
+						postPolicy=dom.select(
+							[['', 'Based on public setting above'], ['members', 'Members only'], ['anyone', 'Anyone'], ['moderated', 'Moderated by owners']].map(t => dom.option(t[1], attr.value(t[0]), alias.PostPolicy === t[0] ? attr.selected('') : [])),
+						),
+					),
+				),
+				dom.label(
+					listHeaders=dom.input(attr.type('checkbox'), alias.ListHeaders ? attr.checked('') : []),
+					' Add List-Id, List-Post and List-Unsubscribe headers to delivered messages',
+				),
+				dom.label(
+					'Owners',
+					attr.title('Addresses of local accounts that can add and remove members in the account web interface, and that moderate a moderated list. One address per line.'),
+					dom.div(owners=dom.textarea(attr.rows('3'), (alias.Owners || []).join('\n'))),
+				),
+				dom.div(style({marginTop: '1ex'}), dom.submitbutton('Save')),
+			),
+		),
+		dom.br(),
+
 		dom.h2('Members'),
 		dom.p('Members receive messages sent to the alias. If a member address is in the message From header, the member will not receive the message.'),
 		dom.table(
@@ -2067,17 +3444,20 @@ const domainAlias = async (d: string, aliasLocalpart: string) => {
 				dom.tr(
 					dom.th('Address'),
 					dom.th('Account'),
+					dom.th('Delivery', attr.title('Delivery preference of the member, set by the member in the account web interface.')),
 					dom.th(),
 				),
 			),
 			dom.tbody(
 				(alias.Addresses || []).map((address, index) => {
 					const pa = (alias.ParsedAddresses || [])[index]
+					const m = (alias.Members || {})[address]
 					return dom.tr(
 						dom.td(prewrap(address)),
 						dom.td(dom.a(pa.AccountName, attr.href('#accounts/l/'+pa.AccountName))),
+						dom.td(m?.NoDelivery ? 'None' : (m?.Mailbox ? 'Mailbox '+m.Mailbox : 'Default')),
 						dom.td(
-							dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 								await check(e.target! as HTMLButtonElement, client.AliasAddressesRemove(aliasLocalpart, d, [address]))
 								window.location.reload() // todo: reload less
 							}),
@@ -2088,7 +3468,7 @@ const domainAlias = async (d: string, aliasLocalpart: string) => {
 			dom.tfoot(
 				dom.tr(
 					dom.td(
-						attr.colspan('3'),
+						attr.colspan('4'),
 						dom.form(
 							async function submit(e: SubmitEvent) {
 								e.preventDefault()
@@ -2107,6 +3487,130 @@ const domainAlias = async (d: string, aliasLocalpart: string) => {
 		),
 		dom.br(),
 
+		dom.h2('Mailing list'),
+		dom.p('A mailing list also has subscribers, which can be external addresses. Subscribers receive messages sent to the list through the queue, and can post to the list like members. Email commands are sent to the list address with a catchall separator, e.g. "+subscribe", and subscriptions can be managed on the list/ page of the account web interface.'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const list: api.AliasList | null = mlistEnabled.checked ? {Subscribe: mlistSubscribe.checked, Archive: mlistArchive.checked, BounceLimit: parseInt(mlistBounceLimit.value) || 0} : null
+				await check(mlistFieldset, client.AliasMailingListSave(aliasLocalpart, d, list))
+				window.location.reload() // todo: reload less
+			},
+			mlistFieldset=dom.fieldset(
+				style({display: 'flex', flexDirection: 'column', gap: '.5ex'}),
+				dom.label(
+					mlistEnabled=dom.input(attr.type('checkbox'), alias.List ? attr.checked('') : []),
+					' Enabled',
+					attr.title('Requires a localpart catchall separator for the domain, for the email commands.'),
+				),
+				dom.label(
+					mlistSubscribe=dom.input(attr.type('checkbox'), alias.List?.Subscribe ? attr.checked('') : []),
+					' Anyone can subscribe, confirmed with a message to the subscribing address',
+				),
+				dom.label(
+					mlistArchive=dom.input(attr.type('checkbox'), alias.List?.Archive ? attr.checked('') : []),
+					' Keep messages in an archive',
+				),
+				dom.label(
+					'Bounce limit',
+					attr.title('Number of delivery failures after which a subscriber is unsubscribed. Failures are forgotten after 30 days without failures. Default 5.'),
+					dom.div(mlistBounceLimit=dom.input(attr.type('number'), attr.min('0'), attr.value(alias.List?.BounceLimit ? ''+alias.List.BounceLimit : ''), attr.placeholder('5'))),
+				),
+				dom.div(style({marginTop: '1ex'}), dom.submitbutton('Save')),
+			),
+		),
+		!alias.List ? [] : [
+			dom.br(),
+			dom.h2('Subscribers'),
+			dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Address'),
+						dom.th('Confirmed'),
+						dom.th('Digest'),
+						dom.th('Bounces', attr.title('Number of delivery failures, reset after 30 days without failures.')),
+						dom.th('Created'),
+						dom.th(),
+					),
+				),
+				dom.tbody(
+					subscribers.length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No subscribers.')) : [],
+					subscribers.map(s =>
+						dom.tr(
+							dom.td(prewrap(s.Address)),
+							dom.td(s.Confirmed ? 'Yes' : 'No'),
+							dom.td(s.Digest ? 'Yes' : 'No'),
+							dom.td(''+s.Bounces),
+							dom.td(age(s.Created, false, nowSecs)),
+							dom.td(
+								dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
+									await check(e.target! as HTMLButtonElement, client.ListSubscriberRemove(aliasLocalpart, d, s.ID))
+									window.location.reload() // todo: reload less
+								}),
+							),
+						)
+					),
+				),
+				dom.tfoot(
+					dom.tr(
+						dom.td(
+							attr.colspan('6'),
+							dom.form(
+								async function submit(e: SubmitEvent) {
+									e.preventDefault()
+									e.stopPropagation()
+									await check(subscriberFieldset, client.ListSubscriberAdd(aliasLocalpart, d, subscriberAddress.value, subscriberDigest.checked))
+									window.location.reload() // todo: reload less
+								},
+								subscriberFieldset=dom.fieldset(
+									subscriberAddress=dom.input(attr.required(''), attr.placeholder('localpart@domain')), ' ',
+									dom.label(style({display: 'inline'}), subscriberDigest=dom.input(attr.type('checkbox')), ' Digest'), ' ',
+									dom.submitbutton('Add subscriber', attr.title('Subscribers added by an admin are confirmed immediately.')),
+								),
+							),
+						),
+					),
+				),
+			),
+		],
+		!alias.List?.Archive ? [] : [
+			dom.br(),
+			dom.h2('Archive'),
+			dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Received'),
+						dom.th('From'),
+						dom.th('Subject'),
+						dom.th('Size'),
+						dom.th(),
+					),
+				),
+				dom.tbody(
+					archive.length === 0 ? dom.tr(dom.td(attr.colspan('5'), 'No messages.')) : [],
+					archive.map(m =>
+						dom.tr(
+							dom.td(age(m.Received, false, nowSecs)),
+							dom.td(prewrap(m.From)),
+							dom.td(prewrap(m.Subject)),
+							dom.td(formatSize(m.Size)),
+							dom.td(
+								dom.clickbutton('View', async function click(e: {target: HTMLButtonElement}) {
+									const text = await check(e.target! as HTMLButtonElement, client.ListArchiveMessage(aliasLocalpart, d, m.ID))
+									popup(
+										dom.h1('Message'),
+										dom.pre(style({whiteSpace: 'pre-wrap', maxWidth: '80em'}), text),
+									)
+								}),
+							),
+						)
+					),
+				),
+			),
+		],
+		dom.br(),
+
 		dom.h2('Danger'),
 		dom.form(
 			async function submit(e: SubmitEvent) {
@@ -2145,6 +3649,82 @@ const domainDNSRecords = async (d: string) => {
 	)
 }
 
+const domainReadiness = async (d: string) => {
+	const [readiness, dnsdomain] = await Promise.all([
+		client.DomainReadiness(d),
+		client.ParseDomain(d),
+	])
+
+	const statusColor = (status: string) => status === 'ok' ? green : (status === 'warning' ? yellow : red)
+
+	const render = (r: api.DomainReadiness) => {
+		const items = r.Items || []
+		return [
+			r.Checked.getTime() > 0 ? dom.p(
+				r.Ready ? box(green, 'Domain is ready.') : box(yellow, 'Domain is not ready yet.'),
+				' ', ''+r.Done+' of '+items.length+' checks OK. Last checked ', age(r.Checked, false, 0), '.',
+				r.Checking ? ' Checking again...' : [],
+			) : dom.p('Checking...'),
+			r.Error ? dom.p(box(red, 'Error: '+r.Error)) : [],
+			items.length === 0 ? [] : dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Check'),
+						dom.th('Status'),
+						dom.th('Details'),
+					),
+				),
+				dom.tbody(
+					items.map(it =>
+						dom.tr(
+							dom.td(it.Name),
+							dom.td(box(statusColor(it.Status), it.Status)),
+							dom.td(
+								(it.Errors || []).length === 0 ? [] : dom.ul((it.Errors || []).map(s => dom.li(s))),
+								(it.Warnings || []).length === 0 ? [] : dom.ul((it.Warnings || []).map(s => dom.li(s))),
+								(it.Instructions || []).map(s => dom.pre(dom._class('literal'), style({maxWidth: '60em'}), s)),
+							),
+						),
+					),
+				),
+			),
+			(r.Records || []).length === 0 ? [] : [
+				dom.h2('DNS records'),
+				dom.p('All DNS records for the domain. Records that are already present can be skipped.'),
+				dom.pre(dom._class('literal'), (r.Records || []).join('\n')),
+			],
+		]
+	}
+
+	let resultsElem: HTMLElement
+	const root = dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			crumblink('Domain ' + domainString(dnsdomain), '#domains/'+d),
+			'Readiness',
+		),
+		dom.p('Checklist of DNS records and configuration for sending and receiving email with this domain. Add the DNS records below, this page checks again periodically while open.'),
+		resultsElem=dom.div(render(readiness)),
+	)
+
+	// Poll for new results while this page is shown.
+	const poll = async () => {
+		if (!document.body.contains(root)) {
+			return
+		}
+		try {
+			const r = await client.DomainReadiness(d)
+			dom._kids(resultsElem, render(r))
+		} catch (err) {
+			console.log('polling domain readiness', err)
+		}
+		window.setTimeout(poll, 5*1000)
+	}
+	window.setTimeout(poll, 5*1000)
+
+	return root
+}
+
 const domainDNSCheck = async (d: string) => {
 	const [checks, dnsdomain] = await Promise.all([
 		client.CheckDomain(d),
@@ -2479,7 +4059,7 @@ const dmarcEvaluations = async () => {
 						dom.td(ba.Until.toISOString()),
 						dom.td(ba.Comment),
 						dom.td(
-							dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 								await check(e.target! as HTMLButtonElement, client.DMARCSuppressRemove(ba.ID))
 								window.location.reload() // todo: only reload the list
 							}),
@@ -2633,15 +4213,128 @@ const period = (start: Date, end: Date) => {
 	return dom.span(beginstr + ' - ' + endstr, title)
 }
 
+const renderDMARCAnalytics = (a: api.Analytics) => {
+	const pct = (n: number, total: number) => total === 0 ? '-' : (Math.round(n*1000/total)/10) + '%'
+	const maxDay = Math.max(1, ...(a.Days || []).map(d => d.Total))
+	const sourcesTable = (sources: api.AnalyticsSource[] | null) =>
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Source IP'),
+					dom.th('Messages'),
+					dom.th('DMARC fail'),
+					dom.th('DKIM fail', attr.title('Messages without aligned DKIM pass.')),
+					dom.th('SPF fail', attr.title('Messages without aligned SPF pass.')),
+					dom.th('Header from', attr.title('Domains in From-header of messages.')),
+				),
+			),
+			dom.tbody(
+				(sources || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'None')) : [],
+				(sources || []).map(src =>
+					dom.tr(
+						dom.td(src.IP),
+						dom.td(style({textAlign: 'right'}), '' + src.Total),
+						dom.td(style({textAlign: 'right'}), src.Fail > 0 ? style({backgroundColor: red}) : [], '' + src.Fail + ' (' + pct(src.Fail, src.Total) + ')'),
+						dom.td(style({textAlign: 'right'}), '' + src.DKIMFail),
+						dom.td(style({textAlign: 'right'}), '' + src.SPFFail),
+						dom.td((src.Domains || []).join(', ')),
+					)
+				),
+			),
+		)
+
+	return [
+		dom.h2('Analytics'),
+		dom.p('' + a.Reports + ' reports about ' + a.Total + ' messages. ', 'DMARC pass: ' + a.Pass + ' (' + pct(a.Pass, a.Total) + '), fail: ' + a.Fail + ' (' + pct(a.Fail, a.Total) + '). ', 'Quarantined: ' + a.Quarantine + ', rejected: ' + a.Reject + '.'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th(),
+					dom.th('Aligned pass', attr.title('Passed and aligned with the From-header domain, as required for DMARC.')),
+					dom.th('Authentication pass', attr.title('Passed, whether aligned or not.')),
+				),
+			),
+			dom.tbody(
+				dom.tr(dom.td('DKIM'), dom.td('' + a.DKIMAlignedPass + ' (' + pct(a.DKIMAlignedPass, a.Total) + ')'), dom.td('' + a.DKIMAuthPass + ' (' + pct(a.DKIMAuthPass, a.Total) + ')')),
+				dom.tr(dom.td('SPF'), dom.td('' + a.SPFAlignedPass + ' (' + pct(a.SPFAlignedPass, a.Total) + ')'), dom.td('' + a.SPFAuthPass + ' (' + pct(a.SPFAuthPass, a.Total) + ')')),
+			),
+		),
+		dom.br(),
+		dom.h3('Messages per day'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Day (UTC)', attr.title('Day the reporting period started.')),
+					dom.th('Messages'),
+					dom.th('DMARC fail'),
+					dom.th('DKIM fail'),
+					dom.th('SPF fail'),
+					dom.th(),
+				),
+			),
+			dom.tbody(
+				(a.Days || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No reports.')) : [],
+				(a.Days || []).map(d =>
+					dom.tr(
+						dom.td(d.Day),
+						dom.td(style({textAlign: 'right'}), '' + d.Total),
+						dom.td(style({textAlign: 'right'}), '' + d.Fail + ' (' + pct(d.Fail, d.Total) + ')'),
+						dom.td(style({textAlign: 'right'}), '' + d.DKIMFail),
+						dom.td(style({textAlign: 'right'}), '' + d.SPFFail),
+						dom.td(
+							attr.title('Messages passing DMARC in green, failing in red.'),
+							dom.div(style({display: 'inline-block', height: '1em', width: ((d.Total-d.Fail)*20/maxDay) + 'em', backgroundColor: green})),
+							dom.div(style({display: 'inline-block', height: '1em', width: (d.Fail*20/maxDay) + 'em', backgroundColor: red})),
+						),
+					)
+				),
+			),
+		),
+		dom.br(),
+		dom.h3('Top sources'),
+		sourcesTable(a.Sources),
+		dom.br(),
+		dom.h3('Top failing sources', attr.title('Source IPs sending most messages failing DMARC, possibly abusing the domain, or legitimate services that are not configured for DKIM/SPF.')),
+		sourcesTable(a.FailingSources),
+		dom.br(),
+		dom.h3('Reporters'),
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Organisation'),
+					dom.th('Reports'),
+					dom.th('Messages'),
+					dom.th('DMARC fail'),
+				),
+			),
+			dom.tbody(
+				(a.Reporters || []).length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [],
+				(a.Reporters || []).map(r =>
+					dom.tr(
+						dom.td(r.OrgName),
+						dom.td(style({textAlign: 'right'}), '' + r.Reports),
+						dom.td(style({textAlign: 'right'}), '' + r.Total),
+						dom.td(style({textAlign: 'right'}), '' + r.Fail + ' (' + pct(r.Fail, r.Total) + ')'),
+					)
+				),
+			),
+		),
+		dom.br(),
+	]
+}
+
 const domainDMARC = async (d: string) => {
 	const end = new Date()
+	const nowSecs = end.getTime()/1000
 	const start = new Date(new Date().getTime() - 30*24*3600*1000)
-	const [reports, dnsdomain] = await Promise.all([
+	const [reports, analytics, failureReports, dnsdomain] = await Promise.all([
 		client.DMARCReports(start, end, d),
+		client.DMARCAnalytics(start, end, d),
+		client.DMARCFailureReports(start, end, d),
 		client.Domain(d),
 	])
 
-	// todo future: table sorting? period selection (last day, 7 days, 1 month, 1 year, custom period)? collapse rows for a report? show totals per report? a simple bar graph to visualize messages and dmarc/dkim/spf fails? similar for TLSRPT.
+	// todo future: table sorting? period selection (last day, 7 days, 1 month, 1 year, custom period)? collapse rows for a report? show totals per report? similar for TLSRPT.
 
 	return dom.div(
 		crumbs(
@@ -2651,6 +4344,8 @@ const domainDMARC = async (d: string) => {
 		),
 		dom.p('DMARC reports are periodically sent by other mail servers that received an email message with a "From" header with our domain. Domains can have a DMARC DNS record that asks other mail servers to send these aggregate reports for analysis.'),
 		dom.p('Below the DMARC aggregate reports for the past 30 days.'),
+		renderDMARCAnalytics(analytics),
+		dom.h2('Reports'),
 		(reports || []).length === 0 ? dom.div('No DMARC reports for domain.') :
 		dom.table(dom._class('hover'),
 			dom.thead(
@@ -2800,7 +4495,64 @@ const domainDMARC = async (d: string) => {
 					})
 				}),
 			),
-		)
+		),
+		dom.br(),
+		dom.h2('Failure reports'),
+		dom.p('Failure reports are about individual messages that failed DMARC, sent by mail servers if the DMARC DNS record of the domain has a "ruf" field with reporting addresses. Few mail servers send them. Below the failure reports received in the past 30 days.'),
+		(failureReports || []).length === 0 ? dom.div('No DMARC failure reports for domain.') :
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('ID'),
+					dom.th('Received'),
+					dom.th('Reporter', attr.title('Domain of the From address of the report message.')),
+					dom.th('Source IP', attr.title('IP address of the mail server that sent the failing message.')),
+					dom.th('Mail from', attr.title('SMTP MAIL FROM of the failing message.')),
+					dom.th('Auth failure', attr.title('Authentication mechanism that failed.')),
+					dom.th('Aligned', attr.title('Mechanisms that passed with alignment.')),
+					dom.th('Delivery result', attr.title('What the reporting mail server did with the message.')),
+				),
+			),
+			dom.tbody(
+				(failureReports || []).map(r =>
+					dom.tr(
+						dom.td(dom.a(attr.href('#domains/' + d + '/dmarc/failure/' + r.ID), '' + r.ID)),
+						dom.td(age(r.Received, false, nowSecs)),
+						dom.td(r.FromDomain),
+						dom.td(r.SourceIP),
+						dom.td(r.OriginalMailFrom),
+						dom.td(r.AuthFailure),
+						dom.td(r.IdentityAlignment),
+						dom.td(r.DeliveryResult),
+					)
+				),
+			),
+		),
+	)
+}
+
+const domainDMARCFailureReport = async (d: string, reportID: number) => {
+	const [report, dnsdomain] = await Promise.all([
+		client.DMARCFailureReportID(d, reportID),
+		client.Domain(d),
+	])
+
+	const header = report.Header
+	report.Header = ''
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			crumblink('Domain ' + domainString(dnsdomain), '#domains/'+d),
+			crumblink('DMARC aggregate reports', '#domains/' + d + '/dmarc'),
+			'Failure report ' + reportID
+		),
+		dom.p('Below is the failure report as received from the remote mail server.'),
+		dom.div(dom._class('literal'), JSON.stringify(report, null, '\t')),
+		dom.br(),
+		dom.h2('Message header'),
+		dom.p('Header of the message that failed DMARC, possibly redacted by the reporter.'),
+		dom.div(dom._class('literal'), header || '(none)'),
 	)
 }
 
@@ -2968,7 +4720,7 @@ const tlsrptResults = async () => {
 						dom.td(ba.Until.toISOString()),
 						dom.td(ba.Comment),
 						dom.td(
-							dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							dom.clickbutton('Remove', async function click(e: {target: HTMLButtonElement}) {
 								await check(e.target! as HTMLButtonElement, client.TLSRPTSuppressRemove(ba.ID))
 								window.location.reload() // todo: only reload the list
 							}),
@@ -3055,44 +4807,151 @@ const tlsrptReports = async () => {
 			crumblink('TLSRPT', '#tlsrpt'),
 			'Reports'
 		),
-		dom.p('TLSRPT (TLS reporting) is a mechanism to request feedback from other mail servers about TLS connections to your mail server. If is typically used along with MTA-STS and/or DANE to enforce that SMTP connections are protected with TLS. Mail servers implementing TLSRPT will typically send a daily report with both successful and failed connection counts, including details about failures.'),
-		renderTLSRPTSummaries(summaries || [])
-	)
-}
-
-const renderTLSRPTSummaries = (summaries: api.TLSRPTSummary[]) => {
-	return [
-		dom.p('Below a summary of TLS reports for the past 30 days.'),
-		summaries.length === 0 ? dom.div(box(yellow, 'No domains with TLS reports.')) :
+		dom.p('TLSRPT (TLS reporting) is a mechanism to request feedback from other mail servers about TLS connections to your mail server. If is typically used along with MTA-STS and/or DANE to enforce that SMTP connections are protected with TLS. Mail servers implementing TLSRPT will typically send a daily report with both successful and failed connection counts, including details about failures.'),
+		renderTLSRPTSummaries(summaries || [])
+	)
+}
+
+const renderTLSRPTSummaries = (summaries: api.TLSRPTSummary[]) => {
+	return [
+		dom.p('Below a summary of TLS reports for the past 30 days.'),
+		summaries.length === 0 ? dom.div(box(yellow, 'No domains with TLS reports.')) :
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Policy domain', attr.title('Policy domain the report is about. The recipient domain for MTA-STS, the TLSA base domain for DANE.')),
+					dom.th('Successes', attr.title('Number of successful SMTP STARTTLS sessions.')),
+					dom.th('Failures', attr.title('Number of failed SMTP STARTTLS sessions.')),
+					dom.th('Failure details', attr.title('Details about connection failures.')),
+				)
+			),
+			dom.tbody(
+				summaries.map(r =>
+					dom.tr(
+						dom.td(dom.a(attr.href('#tlsrpt/reports/' + domainName(r.PolicyDomain)), attr.title('See report details.'), domainName(r.PolicyDomain))),
+						dom.td(style({textAlign: 'right'}), '' + r.Success),
+						dom.td(style({textAlign: 'right'}), '' + r.Failure),
+						dom.td(!r.ResultTypeCounts ? [] : Object.entries(r.ResultTypeCounts).map(kv => kv[0] + ': ' + kv[1]).join('; ')),
+					)
+				),
+			),
+		)
+	]
+}
+
+const renderTLSRPTStats = (s: api.Stats, failureTypes: api.FailureType[]) => {
+	const pct = (n: number, total: number) => total === 0 ? '-' : (Math.round(n*1000/total)/10) + '%'
+	const alignRight = style({textAlign: 'right'})
+	const total = s.Success + s.Failure
+	return [
+		dom.h2('Statistics'),
+		dom.p('' + s.Reports + ' reports about ' + total + ' sessions. ', 'Successful: ' + s.Success + ', failed: ' + s.Failure + ' (' + pct(s.Failure, total) + ').'),
+		dom.h3('Failures by type'),
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Category', attr.title('Kind of problem: STARTTLS not supported, certificate problems, DANE (TLSA/DNSSEC) failures, MTA-STS policy failures, or other.')),
+					dom.th('Result type'),
+					dom.th('Failed sessions'),
+					dom.th('Reports', attr.title('Number of reports mentioning this result type.')),
+					dom.th('MX hosts', attr.title('Receiving MX hosts mentioned in failure details.')),
+					dom.th('Reason codes'),
+				),
+			),
+			dom.tbody(
+				(s.ResultTypes || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No failures.')) : [],
+				(s.ResultTypes || []).map(rt =>
+					dom.tr(
+						dom.td(rt.Category),
+						dom.td(rt.ResultType),
+						dom.td(alignRight, '' + rt.Failure + ' (' + pct(rt.Failure, total) + ')'),
+						dom.td(alignRight, '' + rt.Reports),
+						dom.td((rt.MXHosts || []).join(', ')),
+						dom.td((rt.Reasons || []).join(', ')),
+					)
+				),
+			),
+		),
+		dom.br(),
+		dom.h3('Sessions per day'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Day (UTC)', attr.title('Day the reporting period started.')),
+					dom.th('Successful'),
+					dom.th('Failed'),
+				),
+			),
+			dom.tbody(
+				(s.Days || []).length === 0 ? dom.tr(dom.td(attr.colspan('3'), 'No reports.')) : [],
+				(s.Days || []).map(d =>
+					dom.tr(
+						dom.td(d.Day),
+						dom.td(alignRight, '' + d.Success),
+						dom.td(alignRight, d.Failure > 0 ? style({backgroundColor: red}) : [], '' + d.Failure + ' (' + pct(d.Failure, d.Success+d.Failure) + ')'),
+					)
+				),
+			),
+		),
+		dom.br(),
+		dom.h3('Reporters'),
 		dom.table(dom._class('hover'),
 			dom.thead(
 				dom.tr(
-					dom.th('Policy domain', attr.title('Policy domain the report is about. The recipient domain for MTA-STS, the TLSA base domain for DANE.')),
-					dom.th('Successes', attr.title('Number of successful SMTP STARTTLS sessions.')),
-					dom.th('Failures', attr.title('Number of failed SMTP STARTTLS sessions.')),
-					dom.th('Failure details', attr.title('Details about connection failures.')),
-				)
+					dom.th('Organization'),
+					dom.th('Reports'),
+					dom.th('Successful'),
+					dom.th('Failed'),
+				),
 			),
 			dom.tbody(
-				summaries.map(r =>
+				(s.Reporters || []).length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [],
+				(s.Reporters || []).map(r =>
 					dom.tr(
-						dom.td(dom.a(attr.href('#tlsrpt/reports/' + domainName(r.PolicyDomain)), attr.title('See report details.'), domainName(r.PolicyDomain))),
-						dom.td(style({textAlign: 'right'}), '' + r.Success),
-						dom.td(style({textAlign: 'right'}), '' + r.Failure),
-						dom.td(!r.ResultTypeCounts ? [] : Object.entries(r.ResultTypeCounts).map(kv => kv[0] + ': ' + kv[1]).join('; ')),
+						dom.td(r.OrganizationName),
+						dom.td(alignRight, '' + r.Reports),
+						dom.td(alignRight, '' + r.Success),
+						dom.td(alignRight, '' + r.Failure),
 					)
 				),
 			),
-		)
+		),
+		dom.br(),
+		dom.h3('Failure types seen'),
+		dom.p('An alert message is delivered to the TLS reporting mailbox (or postmaster) when a report contains a failure type not seen before for the domain.'),
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Result type'),
+					dom.th('First seen'),
+					dom.th('Last seen'),
+					dom.th('Failed sessions'),
+				),
+			),
+			dom.tbody(
+				failureTypes.length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'None')) : [],
+				failureTypes.map(ft =>
+					dom.tr(
+						dom.td(ft.ResultType),
+						dom.td(ft.First.toISOString()),
+						dom.td(ft.Last.toISOString()),
+						dom.td(alignRight, '' + ft.Sessions),
+					)
+				),
+			),
+		),
+		dom.br(),
 	]
 }
 
 const domainTLSRPT = async (d: string) => {
 	const end = new Date()
 	const start = new Date(new Date().getTime() - 30*24*3600*1000)
-	const [records, dnsdomain] = await Promise.all([
+	const [records, dnsdomain, stats, failureTypes] = await Promise.all([
 		client.TLSReports(start, end, d),
 		client.ParseDomain(d),
+		client.TLSRPTStats(start, end, d),
+		client.TLSRPTFailureTypes(d),
 	])
 
 	const policyType = (policy: api.ResultPolicy) => {
@@ -3114,6 +4973,8 @@ const domainTLSRPT = async (d: string) => {
 			'Domain '+domainString(dnsdomain),
 		),
 		dom.p('TLSRPT (TLS reporting) is a mechanism to request feedback from other mail servers about TLS connections to your mail server. If is typically used along with MTA-STS and/or DANE to enforce that SMTP connections are protected with TLS. Mail servers implementing TLSRPT will typically send a daily report with both successful and failed connection counts, including details about failures.'),
+		renderTLSRPTStats(stats, failureTypes || []),
+		dom.h2('Reports'),
 		dom.p('Below the TLS reports for the past 30 days.'),
 		(records || []).length === 0 ? dom.div('No TLS reports for domain.') :
 		dom.table(dom._class('hover'),
@@ -3344,12 +5205,301 @@ const dnsbl = async () => {
 	)
 }
 
+const senderReputation = async () => {
+	const senders = await client.SenderReputations('', false)
+	const nowSecs = new Date().getTime()/1000
+
+	let fieldset: HTMLFieldSetElement
+	let senderType: HTMLSelectElement
+	let value: HTMLInputElement
+	let override: HTMLSelectElement
+
+	const ratio = (n: number, total: number) => total ? '' + n + ' of ' + total : '-'
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'Sender reputation',
+		),
+		dom.p('Reputation of sending domains (organizational domain of the message From address) and IPs (IPv6 per /64) of incoming deliveries, aggregated over all accounts. The reputation is used during delivery when an account has no conclusive reputation of its own for a sender. An override takes precedence: "allow" always accepts messages, "quarantine" always delivers to the Junk mailbox.'),
+		dom.h2('Set override'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				await check(fieldset, client.SenderReputationOverride(senderType.value as api.SenderType, value.value, override.value as api.Override))
+				window.location.reload() // todo: only reload the list
+			},
+			fieldset=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					'Type',
+					dom.br(),
+					senderType=dom.select(
+						dom.option('Domain', attr.value(api.SenderType.SenderDomain)),
+						dom.option('IP', attr.value(api.SenderType.SenderIP)),
+					),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Domain or IP',
+					dom.br(),
+					value=dom.input(attr.required('')),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'Override',
+					dom.br(),
+					override=dom.select(
+						dom.option('Allow', attr.value(api.Override.OverrideAllow)),
+						dom.option('Quarantine', attr.value(api.Override.OverrideQuarantine)),
+						dom.option('None', attr.value(api.Override.OverrideNone)),
+					),
+				),
+				' ',
+				dom.submitbutton('Save'),
+			),
+		),
+		dom.br(),
+		dom.h2('Senders'),
+		dom.p('The 1000 most recently seen senders. Deliveries for domains are only counted as accepted or rejected for messages with a validated From address.'),
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Type'),
+					dom.th('Sender'),
+					dom.th('Last seen'),
+					dom.th('Accepted', attr.title('Delivery attempts accepted for at least one recipient.')),
+					dom.th('Rejected', attr.title('Delivery attempts rejected for at least one recipient, e.g. as junk.')),
+					dom.th('Unknown recipients'),
+					dom.th('SPF pass'),
+					dom.th('DKIM pass'),
+					dom.th('DMARC pass/fail'),
+					dom.th('Junk', attr.title('Messages marked as junk by users, of all messages marked as junk or not junk.')),
+					dom.th('Spamtraps', attr.title('Messages sent to spamtrap addresses.')),
+					dom.th('Override'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				(senders || []).length === 0 ? dom.tr(dom.td(attr.colspan('13'), 'No senders.')) : [],
+				(senders || []).map(s =>
+					dom.tr(
+						dom.td(s.Type),
+						dom.td(s.Value),
+						dom.td(age(s.Last, false, nowSecs)),
+						dom.td(style({textAlign: 'right'}), '' + s.Accepted),
+						dom.td(style({textAlign: 'right'}), '' + s.Rejected),
+						dom.td(style({textAlign: 'right'}), '' + s.UnknownRecipients),
+						dom.td(style({textAlign: 'right'}), '' + s.SPFPass),
+						dom.td(style({textAlign: 'right'}), '' + s.DKIMPass),
+						dom.td(style({textAlign: 'right'}), '' + s.DMARCPass + '/' + s.DMARCFail),
+						dom.td(style({textAlign: 'right'}), ratio(s.Junk, s.Junk + s.Notjunk)),
+						dom.td(style({textAlign: 'right'}), '' + s.Spamtraps),
+						dom.td(s.Override ? dom.span(s.Override, attr.title('Set ' + s.OverrideUpdated.toISOString())) : ''),
+						dom.td(
+							s.Override ? dom.clickbutton('Remove override', async function click(e: MouseEvent) {
+								await check(e.target! as HTMLButtonElement, client.SenderReputationOverride(s.Type, s.Value, api.Override.OverrideNone))
+								window.location.reload() // todo: only reload the list
+							}) : [
+								dom.clickbutton('Allow', async function click(e: MouseEvent) {
+									await check(e.target! as HTMLButtonElement, client.SenderReputationOverride(s.Type, s.Value, api.Override.OverrideAllow))
+									window.location.reload() // todo: only reload the list
+								}),
+								' ',
+								dom.clickbutton('Quarantine', async function click(e: MouseEvent) {
+									await check(e.target! as HTMLButtonElement, client.SenderReputationOverride(s.Type, s.Value, api.Override.OverrideQuarantine))
+									window.location.reload() // todo: only reload the list
+								}),
+							],
+						),
+					)
+				),
+			),
+		),
+	)
+}
+
+const spfAnalysis = async () => {
+	let fieldset: HTMLFieldSetElement
+	let domain: HTMLInputElement
+	let ip: HTMLInputElement
+	let results: HTMLElement
+
+	const renderRecord = (r: api.AnalysisRecord): HTMLElement => dom.div(
+		dom.div(
+			dom.b(r.Domain), ': ',
+			r.TXT ? dom.span(style({fontFamily: 'monospace'}), r.TXT) : [],
+			r.Error ? box(red, r.Error) : [],
+		),
+		dom.ul(
+			(r.Mechanisms || []).map(m => dom.li(
+				dom.span(style({fontFamily: 'monospace'}), m.Mechanism),
+				m.Expanded ? ' (expanded to ' + m.Expanded + ')' : [],
+				m.DNSLookups ? dom.span(attr.title('Lookup counting against the limit of 10.'), ' +' + m.DNSLookups) : [],
+				m.Void ? ', void lookup' : [],
+				(m.IPs || []).length ? ': ' + (m.IPs || []).join(', ') : [],
+				m.Error ? box(red, m.Error) : [],
+				m.Include ? renderRecord(m.Include) : [],
+			)),
+		),
+	)
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'SPF analysis',
+		),
+		dom.p('Expand the SPF record of a domain fully, following includes and redirects. Evaluation of an SPF record fails when it needs more than 10 DNS lookups, or has more than 2 lookups that do not return records. If an IP is specified, it is used for expanding macros, and is evaluated against the record.'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				dom._kids(results)
+				const [analysis, status, mechanism, errstr] = await check(fieldset, client.SPFAnalyze(domain.value, ip.value))
+				dom._kids(results,
+					dom.h2('Summary'),
+					dom.table(
+						dom.tr(dom.td('DNS lookups'), dom.td(analysis.DNSLookups > 10 ? box(red, '' + analysis.DNSLookups) : '' + analysis.DNSLookups, ' of max 10')),
+						dom.tr(dom.td('Void lookups'), dom.td(analysis.VoidLookups > 2 ? box(red, '' + analysis.VoidLookups) : '' + analysis.VoidLookups, ' of max 2')),
+						dom.tr(dom.td('DNSSEC'), dom.td(analysis.Authentic ? 'All responses DNSSEC-signed' : 'Not all responses DNSSEC-signed')),
+						ip.value ? dom.tr(dom.td('Evaluation of IP'), dom.td(status, mechanism ? ' (mechanism ' + mechanism + ')' : [], errstr ? box(red, errstr) : [])) : [],
+					),
+					(analysis.Errors || []).length ? [dom.h2('Errors'), dom.ul((analysis.Errors || []).map(s => dom.li(box(red, s))))] : [],
+					(analysis.Warnings || []).length ? [dom.h2('Warnings'), dom.ul((analysis.Warnings || []).map(s => dom.li(box(yellow, s))))] : [],
+					dom.h2('Records'),
+					analysis.Record ? renderRecord(analysis.Record) : [],
+				)
+			},
+			fieldset=dom.fieldset(
+				dom.label(
+					style({display: 'inline-block'}),
+					'Domain',
+					dom.br(),
+					domain=dom.input(attr.required('')),
+				),
+				' ',
+				dom.label(
+					style({display: 'inline-block'}),
+					'IP (optional)',
+					dom.br(),
+					ip=dom.input(),
+				),
+				' ',
+				dom.submitbutton('Analyze'),
+			),
+		),
+		results=dom.div(),
+	)
+}
+
+const daneHost = async () => {
+	const r = await client.DANEHostStatus()
+
+	const yes = (b: boolean) => b ? '✓' : ''
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			'DANE TLSA records',
+		),
+		dom.p('DANE TLSA records in DNS let remote mail servers verify the TLS certificate of this host, for incoming SMTP. The records must match the key of the certificate in use. Before a certificate is renewed with a new key, a TLSA record for the new key must be published, and the TTL of the previous records must expire. Publishing records for both the current and next keys allows rolling over without breaking deliveries.'),
+		dom.table(
+			dom.tr(dom.td('Host'), dom.td(r.Host)),
+			dom.tr(dom.td('TLSA DNS name'), dom.td(r.Name)),
+			dom.tr(dom.td('DNSSEC'), dom.td(r.Authentic ? 'TLSA records DNSSEC-signed' : box(yellow, 'Not DNSSEC-signed, DANE not in effect'))),
+		),
+		(r.Errors || []).length ? [dom.h2('Errors'), dom.ul((r.Errors || []).map(s => dom.li(box(red, s))))] : [],
+		(r.Warnings || []).length ? [dom.h2('Warnings'), dom.ul((r.Warnings || []).map(s => dom.li(box(yellow, s))))] : [],
+		dom.br(),
+		dom.h2('Keys'),
+		dom.table(
+			dom.thead(
+				dom.tr(
+					dom.th('Key type'),
+					dom.th('TLSA record'),
+					dom.th('Current', attr.title('Key of a certificate currently in use for the host, with the expiration time of the certificate.')),
+					dom.th('Next', attr.title('Key used for new certificates requested through ACME, the first configured key of its type.')),
+					dom.th('Configured', attr.title('Key is in HostPrivateKeyFiles.')),
+					dom.th('Published', attr.title('Whether a TLSA record for this key is in DNS.')),
+				),
+			),
+			dom.tbody(
+				(r.Keys || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No keys.')) : [],
+				(r.Keys || []).map(k =>
+					dom.tr(
+						dom.td(k.KeyType),
+						dom.td(style({fontFamily: 'monospace'}), k.TLSA),
+						dom.td(k.Current ? ['✓', k.Expires ? ', expires '+k.Expires.toISOString() : ''] : ''),
+						dom.td(yes(k.Next)),
+						dom.td(yes(k.Configured)),
+						dom.td(k.Published ? '✓' : (k.Current || k.Next ? box(red, 'no') : 'no')),
+					)
+				),
+			),
+		),
+		dom.br(),
+		dom.h2('Suggested records'),
+		dom.p('TLSA records for the keys of current certificates and the configured keys. Only publish records when DNSSEC is enabled for the zone.'),
+		(r.Records || []).length === 0 ? dom.p('None.') : dom.pre(dom._class('literal'), (r.Records || []).map(s => r.Name+' TLSA '+s+'\n').join('')),
+		dom.br(),
+		dom.h2('Published records'),
+		(r.Published || []).length === 0 ? dom.p('None.') : dom.pre(dom._class('literal'), (r.Published || []).map(s => r.Name+' TLSA '+s+'\n').join('')),
+	)
+}
+
+// formatSecurity returns a description of the security guarantees that applied
+// to a delivery attempt.
+const formatSecurity = (r: api.MsgResult) => {
+	const l: string[] = []
+	if (r.TLS) {
+		l.push(r.MTASTS || r.DANE ? 'verified tls' : 'unverified tls')
+	} else if (r.RemoteMTA) {
+		l.push('plaintext')
+	}
+	if (r.MTASTS) {
+		l.push('mta-sts')
+	}
+	if (r.DANEStatus) {
+		l.push('dane '+r.DANEStatus)
+	}
+	const lookups = r.DNSLookups || []
+	if (lookups.length > 0) {
+		l.push('dnssec '+lookups.filter(lu => lu.Authentic).length+'/'+lookups.length)
+	}
+	return l.join(', ')
+}
+
+const securityTitle = 'Security mechanisms for the delivery attempt: whether TLS was used and verified, with MTA-STS or DANE. For DANE, the reason it was or was not used, e.g. "insecure" when DNS responses for the destination were not DNSSEC-verified, "no-tlsa" when no TLSA records exist, "required" when TLS was required and verified with DANE. For the most recent attempt, the number of DNSSEC-verified DNS responses out of all DNS lookups.'
+
+const dnsLookupsTable = (lookups: api.Lookup[]) => dom.table(
+	dom.thead(
+		dom.tr(
+			dom.th('Type'), dom.th('Name'), dom.th('DNSSEC', attr.title('Whether the response was DNSSEC-verified by the recursive resolver.')), dom.th('Error'),
+		),
+	),
+	dom.tbody(
+		lookups.length === 0 ? dom.tr(dom.td(attr.colspan('4'), 'No DNS lookups.')) : [],
+		lookups.map(lu =>
+			dom.tr(
+				dom.td(lu.Type),
+				dom.td(lu.Name),
+				dom.td(lu.Authentic ? '✓' : box(yellow, 'no')),
+				dom.td(lu.Error),
+			)
+		),
+	),
+)
+
 const queueList = async () => {
-	let filter: api.Filter = {Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Hold: null, Submitted: '', NextAttempt: '', Transport: null}
+	let filter: api.Filter = {Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Hold: null, Submitted: '', NextAttempt: '', Transport: null, Domain: '', LastError: ''}
 	let sort: api.Sort = {Field: "NextAttempt", LastID: 0, Last: null, Asc: true}
-	let [holdRules, msgs0, transports] = await Promise.all([
+	let [holdRules, msgs0, count, transports] = await Promise.all([
 		client.QueueHoldRuleList(),
 		client.QueueList(filter, sort),
+		client.QueueCount(filter),
 		client.Transports(),
 	])
 	let msgs: api.Msg[] = msgs0 || []
@@ -3377,6 +5527,7 @@ const queueList = async () => {
 	let filterHold: HTMLSelectElement
 	let filterNextAttempt: HTMLInputElement
 	let filterTransport: HTMLSelectElement
+	let filterLastError: HTMLInputElement
 
 	let requiretlsFieldset: HTMLFieldSetElement
 	let requiretls: HTMLSelectElement
@@ -3398,6 +5549,8 @@ const queueList = async () => {
 			Submitted: '',
 			NextAttempt: '',
 			Transport: null,
+			Domain: '',
+			LastError: '',
 		}
 		// Don't want to accidentally operate on all messages.
 		if ((f.IDs || []).length === 0) {
@@ -3408,6 +5561,13 @@ const queueList = async () => {
 
 	const popupDetails = (m: api.Msg) => {
 		const nowSecs = new Date().getTime()/1000
+		const recipient = m.RecipientLocalpart + '@' + ipdomainString(m.RecipientDomain)
+		let rewriteFieldset: HTMLFieldSetElement
+		let rewriteRecipient: HTMLInputElement
+		let rewriteTransport: HTMLSelectElement
+		let rewriteFrom: HTMLInputElement
+		let rewriteSubject: HTMLInputElement
+		let transcriptBox: HTMLElement
 		popup(
 			dom.h1('Details'),
 			dom.table(
@@ -3418,11 +5578,11 @@ const queueList = async () => {
 			dom.table(
 				dom.thead(
 					dom.tr(
-						dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'),
+						dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'), dom.th('Security', attr.title(securityTitle)),
 					),
 				),
 				dom.tbody(
-					(m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No results.')) : [],
+					(m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('7'), 'No results.')) : [],
 					(m.Results || []).map(r =>
 						dom.tr(
 							dom.td(age(r.Start, false, nowSecs)),
@@ -3431,16 +5591,110 @@ const queueList = async () => {
 							dom.td(''+ (r.Code || '')),
 							dom.td(r.Secode),
 							dom.td(r.Error),
+							dom.td(formatSecurity(r)),
+						)
+					),
+				),
+			),
+			dom.br(),
+			dom.h2('Transcript', attr.title('SMTP protocol transcript of the most recent connection for delivering this message, without authentication and message data.')),
+			transcriptBox=dom.div(
+				dom.clickbutton('Show transcript', async function click(e: MouseEvent) {
+					const r = await check(e.target! as HTMLButtonElement, client.QueueMsgTranscript(m.ID))
+					dom._kids(transcriptBox,
+						!r.Transcript ? 'No transcript.' : [
+							dom.div(age(r.Start, false, nowSecs), ', ', r.RemoteMTA, r.TLS ? ', '+r.TLS : ''),
+							dom.pre(dom._class('literal'), style({maxWidth: '60em', whiteSpace: 'pre-wrap'}), r.Transcript),
+							dom.br(),
+							dom.h2('DNS lookups', attr.title('DNS lookups for the delivery attempt, and whether their responses were DNSSEC-verified. DANE is only used when all responses for the destination are DNSSEC-verified.')),
+							dnsLookupsTable(r.DNSLookups || []),
+						],
+					)
+				}),
+			),
+			dom.br(),
+			dom.h2('Changes'),
+			dom.table(
+				dom.thead(
+					dom.tr(
+						dom.th('Time'), dom.th('Change'),
+					),
+				),
+				dom.tbody(
+					(m.Changes || []).length === 0 ? dom.tr(dom.td(attr.colspan('2'), 'No changes.')) : [],
+					(m.Changes || []).map(c =>
+						dom.tr(
+							dom.td(age(c.Time, false, nowSecs)),
+							dom.td(c.Description),
 						)
 					),
 				),
 			),
+			dom.br(),
+			dom.h2('Rewrite', attr.title('Change the envelope or message headers before the next delivery attempt, e.g. to fix a typo in the recipient address. The message is scheduled for immediate delivery. Changing the From or Subject header removes existing DKIM signatures and signs the message again.')),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+					const rw: api.MsgRewrite = {}
+					if (rewriteRecipient.value !== recipient) {
+						rw.Recipient = rewriteRecipient.value
+					}
+					if (rewriteTransport.value !== (m.Transport || '')) {
+						rw.Transport = rewriteTransport.value
+					}
+					if (rewriteFrom.value) {
+						rw.From = rewriteFrom.value
+					}
+					if (rewriteSubject.value !== m.Subject) {
+						rw.Subject = rewriteSubject.value
+					}
+					await check(rewriteFieldset, client.QueueMsgRewrite(m.ID, rw))
+					window.location.reload() // todo: reload less
+				},
+				rewriteFieldset=dom.fieldset(
+					dom.label(
+						style({display: 'inline-block'}),
+						'Recipient',
+						dom.br(),
+						rewriteRecipient=dom.input(attr.value(recipient), attr.required('')),
+					),
+					' ',
+					dom.label(
+						style({display: 'inline-block'}),
+						'Transport',
+						dom.br(),
+						rewriteTransport=dom.select(
+							dom.option('(default)', attr.value('')),
+							Object.keys(transports || []).sort().map(t => dom.option(t, t === m.Transport ? attr.selected('') : [])),
+						),
+					),
+					' ',
+					dom.label(
+						style({display: 'inline-block'}),
+						dom.span('From header', attr.title('Leave empty to keep the From header. Example: "Name <user@example.org>".')),
+						dom.br(),
+						rewriteFrom=dom.input(),
+					),
+					' ',
+					dom.label(
+						style({display: 'inline-block'}),
+						'Subject',
+						dom.br(),
+						rewriteSubject=dom.input(attr.value(m.Subject)),
+					),
+					' ',
+					dom.submitbutton('Rewrite'),
+				),
+			),
 		)
 	}
 
 	let tbody = dom.tbody()
+	const countElem = dom.span()
 
 	const render = () => {
+		dom._kids(countElem, ' ('+count+' matching)')
 		toggles = new Map<number, HTMLInputElement>()
 		for (const m of msgs) {
 			toggles.set(m.ID, dom.input(attr.type('checkbox'), msgs.length === 1 ? attr.checked('') : [], ))
@@ -3496,7 +5750,7 @@ const queueList = async () => {
 			'Queue',
 		),
 
-		dom.p(dom.a(attr.href('#queue/retired'), 'Retired messages')),
+		dom.p(dom.a(attr.href('#queue/retired'), 'Retired messages'), ', ', dom.a(attr.href('#queue/hosts'), 'Remote host health')),
 		dom.h2('Hold rules', attr.title('Messages submitted to the queue that match a hold rule are automatically marked as "on hold", preventing delivery until explicitly taken off hold again.')),
 		dom.form(
 			attr.id('holdRuleForm'),
@@ -3589,6 +5843,8 @@ const queueList = async () => {
 					Submitted: filterSubmitted.value,
 					NextAttempt: filterNextAttempt.value,
 					Transport: !filterTransport.value ? null : (filterTransport.value === '(default)' ? '' : filterTransport.value),
+					Domain: '',
+					LastError: filterLastError.value,
 				}
 				sort = {
 					Field: sortElem.value.startsWith('nextattempt') ? 'NextAttempt' : 'Queued',
@@ -3597,12 +5853,14 @@ const queueList = async () => {
 					Asc: sortElem.value.endsWith('asc'),
 				}
 				tbody.classList.add('loadstart')
-				msgs = await check({disabled: false}, client.QueueList(filter, sort)) || []
+				const [l, n] = await check({disabled: false}, Promise.all([client.QueueList(filter, sort), client.QueueCount(filter)]))
+				msgs = l || []
+				count = n
 				render()
 			},
 		),
 
-		dom.h2('Messages'),
+		dom.h2('Messages', countElem),
 		dom.table(dom._class('hover'),
 			style({width: '100%'}),
 			dom.thead(
@@ -3627,7 +5885,7 @@ const queueList = async () => {
 					),
 					dom.td(filterNextAttempt=dom.input(attr.form('queuefilter'), style({width: '7em'}), attr.title('Example: ">1h" for filtering messages to be delivered in more than 1 hour, or "<now" for messages to be delivered as soon as possible.'))),
 					dom.td(),
-					dom.td(),
+					dom.td(filterLastError=dom.input(attr.form('queuefilter'), attr.title('Text in the error of the last delivery attempt, case-insensitive. Example: "greylist".'))),
 					dom.td(
 						filterTransport=dom.select(
 							Object.keys(transports || {}).length === 0 ? style({display: 'none'}) : [],
@@ -3842,6 +6100,67 @@ const queueList = async () => {
 	)
 }
 
+const queueHostHealth = async () => {
+	const hosts = await client.QueueHostHealthList() || []
+	const nowSecs = new Date().getTime()/1000
+
+	const when = (d: Date) => d.getTime() <= 0 ? '-' : age(d, false, nowSecs)
+
+	return dom.div(
+		crumbs(
+			crumblink('Mox Admin', '#'),
+			crumblink('Queue', '#queue'),
+			'Remote host health',
+		),
+
+		dom.p('Outcome of delivery attempts to remote hosts. After failed attempts, a host is put in a cooldown period that doubles with each consecutive failure, up to an hour. During the cooldown, other MX hosts with the same preference are tried first.'),
+		dom.table(dom._class('hover'),
+			dom.thead(
+				dom.tr(
+					dom.th('Host'),
+					dom.th('Last attempt'),
+					dom.th('Last success'),
+					dom.th('Last failure'),
+					dom.th('Attempts'),
+					dom.th('Successes'),
+					dom.th('Connect failures'),
+					dom.th('TLS failures'),
+					dom.th('Temporary failures'),
+					dom.th('Consecutive failures'),
+					dom.th('Cooldown until'),
+					dom.th('Last error'),
+					dom.th('Action'),
+				),
+			),
+			dom.tbody(
+				hosts.length === 0 ? dom.tr(dom.td(attr.colspan('13'), 'No delivery attempts recorded.')) : [],
+				hosts.map(hh =>
+					dom.tr(
+						dom.td(hh.Host),
+						dom.td(when(hh.LastAttempt)),
+						dom.td(when(hh.LastSuccess)),
+						dom.td(when(hh.LastFailure)),
+						dom.td(style({textAlign: 'right'}), ''+hh.Attempts),
+						dom.td(style({textAlign: 'right'}), ''+hh.Successes),
+						dom.td(style({textAlign: 'right'}), ''+hh.ConnectFailures),
+						dom.td(style({textAlign: 'right'}), ''+hh.TLSFailures),
+						dom.td(style({textAlign: 'right'}), ''+hh.TemporaryFailures),
+						dom.td(style({textAlign: 'right'}), ''+hh.ConsecutiveFailures),
+						dom.td(hh.CooldownUntil.getTime() > nowSecs*1000 ? age(hh.CooldownUntil, true, nowSecs) : '-'),
+						dom.td(hh.LastError || '-'),
+						dom.td(
+							dom.clickbutton('Reset', attr.title('Remove the health record for this host, ending its cooldown period.'), async function click(e: MouseEvent) {
+								await check(e.target! as HTMLButtonElement, client.QueueHostHealthReset(hh.Host))
+								window.location.reload() // todo: reload less
+							}),
+						),
+					)
+				),
+			),
+		),
+	)
+}
+
 const retiredList = async () => {
 	let filter: api.RetiredFilter = {Max: parseInt(localStorageGet('adminpaginationsize') || '') || 100, IDs: [], Account: '', From: '', To: '', Submitted: '', LastActivity: '', Transport: null}
 	let sort: api.RetiredSort = {Field: "LastActivity", LastID: 0, Last: null, Asc: false}
@@ -3866,6 +6185,7 @@ const retiredList = async () => {
 
 	const popupDetails = (m: api.MsgRetired) => {
 		const nowSecs = new Date().getTime()/1000
+		const lastResult = (m.Results || []).length > 0 ? (m.Results || [])[(m.Results || []).length-1] : null
 		popup(
 			dom.h1('Details'),
 			dom.table(
@@ -3876,11 +6196,11 @@ const retiredList = async () => {
 			dom.table(
 				dom.thead(
 					dom.tr(
-						dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'),
+						dom.th('Start'), dom.th('Duration'), dom.th('Success'), dom.th('Code'), dom.th('Secode'), dom.th('Error'), dom.th('Security', attr.title(securityTitle)),
 					),
 				),
 				dom.tbody(
-					(m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('6'), 'No results.')) : [],
+					(m.Results || []).length === 0 ? dom.tr(dom.td(attr.colspan('7'), 'No results.')) : [],
 					(m.Results || []).map(r =>
 						dom.tr(
 							dom.td(age(r.Start, false, nowSecs)),
@@ -3889,10 +6209,16 @@ const retiredList = async () => {
 							dom.td(''+ (r.Code || '')),
 							dom.td(r.Secode),
 							dom.td(r.Error),
+							dom.td(formatSecurity(r)),
 						)
 					),
 				),
 			),
+			!lastResult || !lastResult.DNSLookups ? [] : [
+				dom.br(),
+				dom.h2('DNS lookups', attr.title('DNS lookups for the most recent delivery attempt, and whether their responses were DNSSEC-verified.')),
+				dnsLookupsTable(lastResult.DNSLookups),
+			],
 		)
 	}
 
@@ -5345,6 +7671,7 @@ const init = async () => {
 	let curhash: string | undefined
 
 	[moxversion, moxgoos, moxgoarch] = await client.Version()
+	;[adminName, adminUser] = await client.AdminSelf()
 
 	const hashChange = async () => {
 		if (curhash === window.location.hash) {
@@ -5364,14 +7691,28 @@ const init = async () => {
 				root = await config()
 			} else if (h === 'loglevels') {
 				root = await loglevels()
+			} else if (h === 'twofactor') {
+				root = await twofactor()
+			} else if (h === 'adminusers') {
+				root = await adminUsers()
+			} else if (h === 'auditlog') {
+				root = await auditlog()
+			} else if (h === 'signup') {
+				root = await signup()
 			} else if (h === 'accounts') {
 				root = await accounts()
+			} else if (h === 'accounts/storage') {
+				root = await accountsStorage()
 			} else if (h === 'accounts/loginattempts') {
 				root = await loginattempts()
 			} else if (t[0] === 'accounts' && t.length === 3 && t[1] === 'l') {
 				root = await account(t[2])
 			} else if (t[0] === 'accounts' && t.length === 4 && t[1] === 'l' && t[3] === 'loginattempts') {
 				root = await accountloginattempts(t[2])
+			} else if (t[0] === 'accounts' && t.length === 4 && t[1] === 'l' && t[3] === 'junkverdicts') {
+				root = await accountjunkverdicts(t[2], false)
+			} else if (t[0] === 'accounts' && t.length === 5 && t[1] === 'l' && t[3] === 'junkverdicts' && t[4] === 'junk') {
+				root = await accountjunkverdicts(t[2], true)
 			} else if (t[0] === 'domains' && t.length === 2) {
 				root = await domain(t[1])
 			} else if (t[0] === 'domains' && t.length === 4 && t[2] === 'alias') {
@@ -5380,14 +7721,20 @@ const init = async () => {
 				root = await domainDMARC(t[1])
 			} else if (t[0] === 'domains' && t.length === 4 && t[2] === 'dmarc' && parseInt(t[3])) {
 				root = await domainDMARCReport(t[1], parseInt(t[3]))
+			} else if (t[0] === 'domains' && t.length === 5 && t[2] === 'dmarc' && t[3] === 'failure' && parseInt(t[4])) {
+				root = await domainDMARCFailureReport(t[1], parseInt(t[4]))
 			} else if (t[0] === 'domains' && t.length === 3 && t[2] === 'dnscheck') {
 				root = await domainDNSCheck(t[1])
+			} else if (t[0] === 'domains' && t.length === 3 && t[2] === 'readiness') {
+				root = await domainReadiness(t[1])
 			} else if (t[0] === 'domains' && t.length === 3 && t[2] === 'dnsrecords') {
 				root = await domainDNSRecords(t[1])
 			} else if (h === 'queue') {
 				root = await queueList()
 			} else if (h === 'queue/retired') {
 				root = await retiredList()
+			} else if (h === 'queue/hosts') {
+				root = await queueHostHealth()
 			} else if (h === 'webhookqueue') {
 				root = await hooksList()
 			} else if (h === 'webhookqueue/retired') {
@@ -5416,6 +7763,12 @@ const init = async () => {
 				root = await mtasts()
 			} else if (h === 'dnsbl') {
 				root = await dnsbl()
+			} else if (h === 'senderreputation') {
+				root = await senderReputation()
+			} else if (h === 'spf') {
+				root = await spfAnalysis()
+			} else if (h === 'dane') {
+				root = await daneHost()
 			} else if (h === 'routes') {
 				root = await globalRoutes()
 			} else if (h === 'webserver') {
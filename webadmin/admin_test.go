@@ -28,7 +28,10 @@ import (
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/mtasts"
 	"github.com/mjl-/mox/queue"
+	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/tlsrpt"
+	"github.com/mjl-/mox/tlsrptdb"
 	"github.com/mjl-/mox/webauth"
 )
 
@@ -107,18 +110,18 @@ func TestAdminAuth(t *testing.T) {
 	tcheck(t, err, "sherpa handler")
 
 	respRec := httptest.NewRecorder()
-	reqInfo := requestInfo{"", respRec, &http.Request{RemoteAddr: "127.0.0.1:1234"}}
+	reqInfo := requestInfo{"", "", respRec, &http.Request{RemoteAddr: "127.0.0.1:1234"}}
 	ctx := context.WithValue(ctxbg, requestInfoCtxKey, reqInfo)
 
 	// Missing login token.
-	tneedErrorCode(t, "user:error", func() { api.Login(ctx, "", "moxtest123") })
+	tneedErrorCode(t, "user:error", func() { api.Login(ctx, "", "", "moxtest123", "") })
 
 	// Login with loginToken.
 	loginCookie := &http.Cookie{Name: "webadminlogin"}
 	loginCookie.Value = api.LoginPrep(ctx)
 	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
 
-	csrfToken := api.Login(ctx, loginCookie.Value, "moxtest123")
+	csrfToken := api.Login(ctx, loginCookie.Value, "", "moxtest123", "")
 	var sessionCookie *http.Cookie
 	for _, c := range respRec.Result().Cookies() {
 		if c.Name == "webadminsession" {
@@ -133,7 +136,7 @@ func TestAdminAuth(t *testing.T) {
 	// Valid loginToken, but bad credentials.
 	loginCookie.Value = api.LoginPrep(ctx)
 	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "badauth") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(ctx, loginCookie.Value, "", "badauth", "") })
 
 	type httpHeaders [][2]string
 	ctJSON := [2]string{"Content-Type", "application/json; charset=utf-8"}
@@ -210,6 +213,15 @@ func TestAdminAuth(t *testing.T) {
 	testHTTPAuthAPI("GET", "/api/Transports", http.StatusMethodNotAllowed, nil, nil)
 	testHTTPAuthAPI("POST", "/api/Transports", http.StatusOK, httpHeaders{ctJSON}, nil)
 
+	// Calls that make changes are recorded in the audit log, also when they fail.
+	// Transports only reads.
+	testHTTPAuthAPI("POST", "/api/LogLevelRemove", http.StatusOK, httpHeaders{ctJSON}, nil)
+	auditEvents, err := store.AuditList(ctxbg, store.AuditFilter{Kind: store.AuditConfig}, 0)
+	tcheck(t, err, "list audit events")
+	if len(auditEvents) != 1 || auditEvents[0].Action != "LogLevelRemove" || auditEvents[0].Actor != "(admin)" || auditEvents[0].Result == "ok" {
+		t.Fatalf("got audit events %v, expected failed LogLevelRemove", auditEvents)
+	}
+
 	// Logout needs session token.
 	reqInfo.SessionToken = store.SessionToken(strings.SplitN(sessionCookie.Value, " ", 2)[0])
 	ctx = context.WithValue(ctxbg, requestInfoCtxKey, reqInfo)
@@ -263,6 +275,39 @@ func TestAdmin(t *testing.T) {
 	tneedErrorCode(t, "user:error", func() { api.DomainRoutesSave(ctxbg, "mox.example", []config.Route{{Transport: "bogus"}}) })
 	api.DomainRoutesSave(ctxbg, "mox.example", nil)
 
+	api.AccountOutgoingFooterSave(ctxbg, "mjl", &config.OutgoingFooter{Text: []string{"Confidential."}})
+	tneedErrorCode(t, "user:error", func() { api.AccountOutgoingFooterSave(ctxbg, "mjl", &config.OutgoingFooter{}) })
+	api.AccountOutgoingFooterSave(ctxbg, "mjl", nil)
+
+	api.DomainOutgoingFooterSave(ctxbg, "mox.example", &config.OutgoingFooter{Text: []string{"Confidential."}, HTML: "<p>Confidential.</p>"})
+	api.DomainOutgoingFooterSave(ctxbg, "mox.example", nil)
+
+	api.DomainSpamActionsSave(ctxbg, "mox.example", &config.SpamActions{TagThreshold: 0.5, JunkThreshold: 0.8, HighAction: config.SpamHighActionReject})
+	tneedErrorCode(t, "user:error", func() { api.DomainSpamActionsSave(ctxbg, "mox.example", &config.SpamActions{HighAction: "bogus"}) })
+	tneedErrorCode(t, "user:error", func() { api.DomainSpamActionsSave(ctxbg, "mox.example", &config.SpamActions{JunkThreshold: 2}) })
+	api.DomainSpamActionsSave(ctxbg, "mox.example", nil)
+	tneedErrorCode(t, "user:error", func() { api.AccountSpamActionsSave(ctxbg, "mjl", config.SpamActions{TagThreshold: 0.5}) }) // No junk filter.
+
+	api.DomainSpamtrapsSave(ctxbg, "mox.example", []string{"trap"})
+	tcompare(t, mox.Conf.Dynamic.Domains["mox.example"].SpamtrapLocalparts, []smtp.Localpart{"trap"})
+	tneedErrorCode(t, "user:error", func() { api.DomainSpamtrapsSave(ctxbg, "mox.example", []string{"mjl"}) })          // Regular address.
+	tneedErrorCode(t, "user:error", func() { api.DomainSpamtrapsSave(ctxbg, "mox.example", []string{"trap", "Trap"}) }) // Duplicate.
+	api.DomainSpamtrapsSave(ctxbg, "mox.example", nil)
+
+	tcompare(t, api.AccountSendSuspension(ctxbg, "mjl") == nil, true)
+	api.AccountSendResume(ctxbg, "mjl")
+	api.AccountStorage(ctxbg, "mjl")
+
+	api.DomainMessageTemplatesSave(ctxbg, "mox.example", []config.MessageTemplate{{Name: "thanks", Subject: "Thank you", Text: []string{"Hi {{recipient.firstname}},", "", "Thanks!"}}})
+	tneedErrorCode(t, "user:error", func() {
+		api.DomainMessageTemplatesSave(ctxbg, "mox.example", []config.MessageTemplate{{Name: "a"}, {Name: "a"}}) // Duplicate name.
+	})
+	tneedErrorCode(t, "user:error", func() { api.DomainMessageTemplatesSave(ctxbg, "mox.example", []config.MessageTemplate{{}}) }) // Missing name.
+	api.DomainMessageTemplatesSave(ctxbg, "mox.example", nil)
+
+	tneedErrorCode(t, "user:error", func() { api.SPFAnalyze(ctxbg, "bad domain", "") })
+	tneedErrorCode(t, "user:error", func() { api.SPFAnalyze(ctxbg, "mox.example", "bogus") })
+
 	api.RoutesSave(ctxbg, []config.Route{{Transport: "direct"}})
 	tneedErrorCode(t, "user:error", func() { api.RoutesSave(ctxbg, []config.Route{{Transport: "bogus"}}) })
 	api.RoutesSave(ctxbg, nil)
@@ -314,6 +359,16 @@ func TestAdmin(t *testing.T) {
 		api.DomainMTASTSSave(ctxbg, "mox.example", "id0", mtasts.ModeEnforce, time.Hour, []string{"*.*.mail.mox.example"})
 	})
 	api.DomainMTASTSSave(ctxbg, "mox.example", "", mtasts.ModeNone, 0, nil) // Restore.
+	tneedErrorCode(t, "user:error", func() {
+		api.DomainMTASTSPolicySave(ctxbg, "bogus.example", mtasts.ModeTesting, time.Hour, nil)
+	})
+	tneedErrorCode(t, "user:error", func() {
+		api.DomainMTASTSPolicySave(ctxbg, "mox.example", mtasts.Mode("bogus"), time.Hour, nil)
+	})
+	tneedErrorCode(t, "user:error", func() {
+		api.DomainMTASTSPolicySave(ctxbg, "mox.example", mtasts.ModeTesting, 0, nil)
+	})
+	tneedErrorCode(t, "user:error", func() { api.DomainMTASTSRollout(ctxbg, "bogus.example") })
 
 	api.DomainDKIMAdd(ctxbg, "mox.example", "testsel", "ed25519", "sha256", true, true, true, nil, 24*time.Hour)
 	tneedErrorCode(t, "user:error", func() {
@@ -349,6 +404,15 @@ func TestAdmin(t *testing.T) {
 	tneedErrorCode(t, "user:error", func() { api.AliasUpdate(ctxbg, "bogus", "mox.example", true, true, true) })     // Unknown alias localpart.
 	tneedErrorCode(t, "user:error", func() { api.AliasUpdate(ctxbg, "support", "bogus.example", true, true, true) }) // Unknown alias domain.
 
+	api.AliasListSave(ctxbg, "support", "mox.example", "moderated", true, []string{"mjl@mox.example"})
+	tneedErrorCode(t, "user:error", func() { api.AliasListSave(ctxbg, "support", "mox.example", "bogus", false, nil) })                      // Unknown post policy.
+	tneedErrorCode(t, "user:error", func() { api.AliasListSave(ctxbg, "support", "mox.example", "moderated", false, nil) })                  // Moderated without owner.
+	tneedErrorCode(t, "user:error", func() { api.AliasListSave(ctxbg, "support", "mox.example", "", false, []string{"bogus@mox.example"}) }) // Unknown owner.
+	api.AliasListSave(ctxbg, "support", "mox.example", "", false, nil)
+
+	tneedErrorCode(t, "user:error", func() { api.AliasMailingListSave(ctxbg, "support", "mox.example", &config.AliasList{Subscribe: true}) }) // No catchall separator.
+	api.AliasMailingListSave(ctxbg, "support", "mox.example", nil)
+
 	tneedErrorCode(t, "user:error", func() {
 		api.AliasAddressesAdd(ctxbg, "support", "mox.example", []string{"mjl2@mox.example", "mjl2@mox.example"})
 	}) // Cannot add twice.
@@ -376,6 +440,72 @@ func TestAdmin(t *testing.T) {
 
 }
 
+func TestMTASTSRollout(t *testing.T) {
+	d := dns.Domain{ASCII: "mox.example"}
+	now := time.Now()
+	day := 24 * time.Hour
+	record := func(age time.Duration, mode string, failures int64) tlsrptdb.Record {
+		start := now.Add(-age - day)
+		return tlsrptdb.Record{
+			Domain: d.Name(),
+			Report: tlsrpt.Report{
+				DateRange: tlsrpt.TLSRPTDateRange{Start: start, End: start.Add(day)},
+				Policies: []tlsrpt.Result{{
+					Policy:  tlsrpt.ResultPolicy{Type: tlsrpt.STS, String: []string{"version: STSv1", "mode: " + mode}, Domain: d.ASCII},
+					Summary: tlsrpt.Summary{TotalSuccessfulSessionCount: 10, TotalFailureSessionCount: failures},
+				}},
+			},
+		}
+	}
+
+	// No policy, can start testing.
+	r := mtastsRolloutEval(d, config.Domain{}, nil, now)
+	if r.NextMode != string(mtasts.ModeTesting) || !r.Ready {
+		t.Fatalf("unexpected rollout without policy %#v", r)
+	}
+
+	testing := config.Domain{MTASTS: &config.MTASTS{PolicyID: "id0", Mode: mtasts.ModeTesting, MaxAge: day}, TLSRPT: &config.TLSRPT{}}
+	var records []tlsrptdb.Record
+	for i := range 5 {
+		records = append(records, record(time.Duration(i)*day, "testing", 0))
+	}
+	r = mtastsRolloutEval(d, testing, records, now)
+	if r.NextMode != string(mtasts.ModeEnforce) || r.Ready || r.TestingDays != 5 {
+		t.Fatalf("unexpected rollout with too few days %#v", r)
+	}
+
+	// Reports about an enforced policy are not counted.
+	for i := 5; i < 10; i++ {
+		records = append(records, record(time.Duration(i)*day, "enforce", 0))
+	}
+	r = mtastsRolloutEval(d, testing, records, now)
+	if r.Ready || r.TestingDays != 5 {
+		t.Fatalf("unexpected rollout with enforce reports %#v", r)
+	}
+
+	// Old failures are fine.
+	for i := 10; i < 15; i++ {
+		records = append(records, record(time.Duration(i)*day, "testing", 1))
+	}
+	r = mtastsRolloutEval(d, testing, records, now)
+	if !r.Ready || r.TestingDays != 10 || r.TestingFailure != 5 || r.RecentFailure != 0 {
+		t.Fatalf("unexpected rollout with old failures %#v", r)
+	}
+
+	// Recent failures prevent promotion.
+	records = append(records, record(0, "testing", 1))
+	r = mtastsRolloutEval(d, testing, records, now)
+	if r.Ready || r.RecentFailure != 1 {
+		t.Fatalf("unexpected rollout with recent failure %#v", r)
+	}
+
+	enforce := config.Domain{MTASTS: &config.MTASTS{PolicyID: "id1", Mode: mtasts.ModeEnforce, MaxAge: day}}
+	r = mtastsRolloutEval(d, enforce, nil, now)
+	if r.NextMode != "" || r.Ready {
+		t.Fatalf("unexpected rollout for enforced policy %#v", r)
+	}
+}
+
 func TestCheckDomain(t *testing.T) {
 	// NOTE: we aren't currently looking at the results, having the code paths executed is better than nothing.
 
@@ -441,9 +571,34 @@ func TestCheckDomain(t *testing.T) {
 	close(done)
 	dialer := &net.Dialer{Deadline: time.Now().Add(-time.Second), Cancel: done}
 
-	checkDomain(ctxbg, resolver, dialer, "mox.example")
+	cr := checkDomain(ctxbg, resolver, dialer, "mox.example")
 	// todo: check returned data
 
+	// Readiness checklist has the status of each check.
+	items := readinessItems(cr)
+	tcompare(t, len(items), 14)
+	for _, it := range items {
+		exp := "ok"
+		if len(it.Errors) > 0 {
+			exp = "error"
+		} else if len(it.Warnings) > 0 {
+			exp = "warning"
+		}
+		if it.Status != exp {
+			t.Fatalf("readiness item %s has status %q, expected %q", it.Name, it.Status, exp)
+		}
+	}
+
+	// A recent result is returned without starting a new check.
+	readiness.Lock()
+	readiness.domains["mox.example"] = &DomainReadiness{Domain: "mox.example", Checked: time.Now(), Ready: true, Items: items}
+	readiness.Unlock()
+	dr := Admin{}.DomainReadiness(ctxbg, "mox.example")
+	tcompare(t, dr.Checking, false)
+	tcompare(t, dr.Ready, true)
+	tcompare(t, len(dr.Items), 14)
+	tneedErrorCode(t, "user:notFound", func() { Admin{}.DomainReadiness(ctxbg, "bogus.example") })
+
 	Admin{}.Domains(ctxbg)             // todo: check results
 	dnsblsStatus(ctxbg, log, resolver) // todo: check results
 }
@@ -2,6 +2,23 @@
 
 namespace api {
 
+// PasskeyGetOptions are the parameters for navigator.credentials.get in the
+// browser for a passkey login. No credentials are specified: the browser lets
+// the user choose from the passkeys registered for the site.
+export interface PasskeyGetOptions {
+	Challenge?: string | null
+	RPID: string
+	Timeout: number  // In milliseconds.
+}
+
+// PasskeyAssertion is the response from navigator.credentials.get.
+export interface PasskeyAssertion {
+	CredentialID?: string | null
+	ClientDataJSON?: string | null
+	AuthenticatorData?: string | null
+	Signature?: string | null
+}
+
 // CheckResult is the analysis of a domain, its actual configuration (DNS, TLS,
 // connectivity) and the mox configuration. It includes configuration instructions
 // (e.g. DNS records), and warnings and errors encountered.
@@ -278,6 +295,18 @@ export interface ConfigDomain {
 	TLSRPT?: TLSRPT | null
 	Routes?: Route[] | null
 	Aliases?: { [key: string]: Alias }
+	OutgoingHeaderRules?: OutgoingHeaderRules | null
+	OutgoingFooter?: OutgoingFooter | null
+	MessageTemplates?: MessageTemplate[] | null
+	RequireTwoFactor: boolean
+	QuotaMessageSize: number
+	DNSProvisioning?: DNSProvisioning | null
+	Signup?: DomainSignup | null
+	Web?: DomainWeb | null
+	BIMI?: BIMI | null
+	SpamActions?: SpamActions | null
+	SharedJunkFilter?: SharedJunkFilter | null
+	Spamtraps?: string[] | null
 	Domain: Domain
 	LocalpartCatchallSeparatorsEffective?: string[] | null  // Either LocalpartCatchallSeparators, the value of LocalpartCatchallSeparator, or empty.
 }
@@ -285,6 +314,8 @@ export interface ConfigDomain {
 export interface DKIM {
 	Selectors?: { [key: string]: Selector }
 	Sign?: string[] | null
+	SignRules?: DKIMSignRule[] | null
+	Rotation?: DKIMRotation | null
 }
 
 export interface Selector {
@@ -304,11 +335,29 @@ export interface Canonicalization {
 	BodyRelaxed: boolean
 }
 
+// DKIMSignRule selects the DKIM selectors to sign an outgoing message with. All
+// configured match fields must match.
+export interface DKIMSignRule {
+	MsgFromRegexp: string
+	HeadersRegexp?: { [key: string]: string }
+	Account: string
+	Sign?: string[] | null
+	Comment: string
+}
+
+// DKIMRotation configures automatic rotation of DKIM keys.
+export interface DKIMRotation {
+	Interval: number
+	PublishDelay: number
+	RetainPeriod: number
+}
+
 export interface DMARC {
 	Localpart: string
 	Domain: string
 	Account: string
 	Mailbox: string
+	FailureAlertPercentage: number
 	ParsedLocalpart: Localpart  // Lower-case if case-sensitivity is not configured for domain. Not "canonical" for catchall separators for backwards compatibility.
 	DNSDomain: Domain  // Effective domain, always set based on Domain field or Domain where this is configured.
 }
@@ -343,15 +392,35 @@ export interface Alias {
 	PostPublic: boolean
 	ListMembers: boolean
 	AllowMsgFrom: boolean
+	PostPolicy: string
+	Owners?: string[] | null
+	ListHeaders: boolean
+	Members?: { [key: string]: AliasMember }
+	List?: AliasList | null
 	LocalpartStr: string  // In encoded form.
 	Domain: Domain
 	ParsedAddresses?: AliasAddress[] | null  // Matches addresses.
+	ParsedOwners?: AliasAddress[] | null  // Matches Owners.
+}
+
+// AliasMember holds delivery preferences of a member of an alias.
+export interface AliasMember {
+	NoDelivery: boolean
+	Mailbox: string
+}
+
+// AliasList configures the mailing list features of an alias.
+export interface AliasList {
+	Subscribe: boolean
+	Archive: boolean
+	BounceLimit: number
 }
 
 export interface AliasAddress {
 	Address: Address  // Parsed address.
 	AccountName: string  // Looked up.
 	Destination: Destination  // Belonging to address.
+	Member: AliasMember  // Delivery preferences, from Alias.Members.
 }
 
 // Address is a parsed email address.
@@ -382,6 +451,100 @@ export interface Ruleset {
 	ListAllowDNSDomain: Domain
 }
 
+// OutgoingHeaderRules are changes to the message header of outgoing messages.
+// Removals are done first, then From and Reply-To changes, then additions.
+export interface OutgoingHeaderRules {
+	Remove?: string[] | null
+	FromDisplayName: string
+	ReplyTo: string
+	Add?: string[] | null
+}
+
+// OutgoingFooter is text added to the end of the message text of outgoing
+// messages. It is added to text/plain and text/html parts that form the message
+// text, not to attachments. Messages that are signed or encrypted, e.g. with
+// S/MIME or PGP/MIME, are left unchanged, the footer would invalidate the
+// signature.
+export interface OutgoingFooter {
+	Text?: string[] | null
+	HTML: string
+}
+
+// MessageTemplate is a template for composing a message in the webmail. The
+// subject and text can contain placeholders that are replaced when the template
+// is used: {{recipient.name}}, {{recipient.firstname}}, {{recipient.email}},
+// {{sender.name}}, {{sender.email}} and {{date}}.
+export interface MessageTemplate {
+	Name: string
+	Subject: string
+	Text?: string[] | null
+}
+
+// DNSProvisioning configures managing DNS records of a domain through a DNS
+// provider.
+export interface DNSProvisioning {
+	Provider: string
+	Zone: string
+	TTL: number
+	DKIMRotation?: DKIMRotation | null
+}
+
+// DomainSignup configures self-service signup for accounts at a domain.
+export interface DomainSignup {
+	Open: boolean
+	RequireApproval: boolean
+	QuotaMessageSize: number
+}
+
+// DomainWeb customizes the webmail and account web interfaces for a domain.
+export interface DomainWeb {
+	Name: string
+	LogoFile: string
+	LoginText: string
+	Color: string
+	BackgroundColor: string
+	CSSFile: string
+	WebmailDefaults?: WebmailDefaults | null
+	DisabledFeatures?: string[] | null
+}
+
+// WebmailDefaults are the initial webmail settings for new accounts.
+export interface WebmailDefaults {
+	ShowHTML: boolean
+	ShowAddressSecurity: boolean
+	NoShowShortcuts: boolean
+	RemoteContentProxy: boolean
+	UndoSendSeconds: number
+}
+
+// BIMI configures the logo published for a domain with BIMI.
+export interface BIMI {
+	Selector: string
+	LogoFile: string
+	LogoURL: string
+	AuthorityFile: string
+	AuthorityURL: string
+}
+
+// SpamActions configures tiered actions for incoming messages based on the
+// spaminess score of the junk filter. Zero values are unset.
+export interface SpamActions {
+	TagThreshold: number
+	JunkThreshold: number
+	HighAction: string
+}
+
+export interface SharedJunkFilter {
+	Weight: number
+	Onegrams: boolean
+	Twograms: boolean
+	Threegrams: boolean
+	MaxPower: number
+	TopWords: number
+	IgnoreWords: number
+	RareWords: number
+}
+
 export interface Account {
 	OutgoingWebhook?: OutgoingWebhook | null
 	IncomingWebhook?: IncomingWebhook | null
@@ -399,24 +562,37 @@ export interface Account {
 	KeepRejects: boolean
 	AutomaticJunkFlags: AutomaticJunkFlags
 	JunkFilter?: JunkFilter | null  // todo: sane defaults for junkfilter
+	JunkReevaluation?: JunkReevaluation | null
+	Screening?: Screening | null
+	Categories?: Categories | null
 	MaxOutgoingMessagesPerDay: number
 	MaxFirstTimeRecipientsPerDay: number
 	NoFirstTimeSenderDelay: boolean
 	NoCustomPassword: boolean
+	PasswordPolicy?: PasswordPolicy | null
+	RequireTwoFactor: boolean
 	Routes?: Route[] | null
+	OutgoingHeaderRules?: OutgoingHeaderRules | null
+	OutgoingFooter?: OutgoingFooter | null
+	Identities?: Identity[] | null
+	Delegations?: Delegation[] | null
 	DNSDomain: Domain  // Parsed form of Domain.
 	Aliases?: AddressAlias[] | null
+	OwnedAliases?: string[] | null  // Addresses of aliases with an address of this account as owner.
 }
 
 export interface OutgoingWebhook {
 	URL: string
 	Authorization: string
 	Events?: string[] | null
+	SigningKey: string
+	Transcript: boolean
 }
 
 export interface IncomingWebhook {
 	URL: string
 	Authorization: string
+	SigningKey: string
 }
 
 export interface SubjectPass {
@@ -432,6 +608,9 @@ export interface AutomaticJunkFlags {
 
 export interface JunkFilter {
 	Threshold: number
+	TagThreshold: number
+	JunkThreshold: number
+	HighAction: string
 	Onegrams: boolean
 	Twograms: boolean
 	Threegrams: boolean
@@ -441,10 +620,65 @@ export interface JunkFilter {
 	RareWords: number
 }
 
+export interface JunkReevaluation {
+	Window: number
+	Sender: boolean
+	DNSBL: boolean
+	Spamtrap: boolean
+	Notify: boolean
+}
+
+export interface Screening {
+	Mailbox: string
+}
+
+// Categories configures classification of incoming bulk messages. A nil
+// category is not used.
+export interface Categories {
+	Newsletters?: Category | null
+	Notifications?: Category | null
+	Social?: Category | null
+}
+
+// Category is a class of bulk messages, see Categories.
+export interface Category {
+	Mailbox: string
+	Domains?: string[] | null
+}
+
+export interface PasswordPolicy {
+	MinLength: number
+	MinEntropy: number
+	MaxAge: number
+	LockoutFailures: number
+	LockoutPeriod: number
+}
+
+// Identity is an address with display name and signature an account can send
+// messages as in the webmail.
+export interface Identity {
+	Address: string
+	DisplayName: string
+	Signature?: string[] | null
+	SignatureHTML: string
+	ReplyQuoting: string
+	ReplyTo: string
+	Default: boolean
+}
+
+// Delegation gives another account access to mailboxes of an account.
+export interface Delegation {
+	Account: string
+	Mailboxes?: string[] | null
+	Write: boolean
+	Send: string
+}
+
 export interface AddressAlias {
 	SubscriptionAddress: string
 	Alias: Alias  // Without members.
 	MemberAddresses?: string[] | null  // Only if allowed to see.
+	Member: AliasMember  // Delivery preferences of the subscription address.
 }
 
 // PolicyRecord is a cached policy or absence of a policy.
@@ -528,6 +762,60 @@ export interface TLSRPTSummary {
 	ResultTypeCounts?: { [key: string]: number }
 }
 
+// Stats summarizes the TLS reports for a policy domain over a period.
+export interface Stats {
+	PolicyDomain: string  // Empty for all domains.
+	Reports: number
+	Success: number
+	Failure: number
+	Categories?: StatsCategory[] | null  // Most failed sessions first.
+	ResultTypes?: StatsResultType[] | null  // Most failed sessions first.
+	Days?: StatsDay[] | null  // Sorted by day, for trends.
+	Reporters?: StatsReporter[] | null  // Most sessions first.
+}
+
+// StatsCategory holds the number of failed sessions for a failure category.
+export interface StatsCategory {
+	Category: FailureCategory
+	Failure: number
+}
+
+// StatsResultType holds failure details for a result type.
+export interface StatsResultType {
+	ResultType: string
+	Category: FailureCategory
+	Failure: number  // Failed sessions.
+	Reports: number  // Reports mentioning this result type.
+	MXHosts?: string[] | null  // Receiving MX hostnames, as reported.
+	Reasons?: string[] | null  // Failure reason codes, as reported.
+}
+
+// StatsDay holds session counts for reports with a period starting on a day.
+export interface StatsDay {
+	Day: string  // In UTC, "2006-01-02".
+	Success: number
+	Failure: number
+}
+
+// StatsReporter holds session counts for an organization sending reports.
+export interface StatsReporter {
+	OrganizationName: string
+	Reports: number
+	Success: number
+	Failure: number
+}
+
+// FailureType records when a result type was first and last seen in a TLS
+// report for a policy domain. Used to alert about new types of failures.
+export interface FailureType {
+	ID: number
+	PolicyDomain: string  // Unicode.
+	ResultType: string
+	First: Date
+	Last: Date
+	Sessions: number  // Total failed sessions seen.
+}
+
 // DomainFeedback is a single report stored in the database.
 export interface DomainFeedback {
 	ID: number
@@ -612,6 +900,31 @@ export interface SPFAuthResult {
 	Result: string
 }
 
+// DomainFailureReport is a single incoming failure report stored in the database.
+export interface DomainFailureReport {
+	ID: number
+	Received: Date
+	Domain: string  // Domain the report is about, from Reported-Domain.
+	FromDomain: string  // Domain in From-header of the report message.
+	FeedbackType: string  // Should be "auth-failure".
+	UserAgent: string  // Software that generated the report.
+	Version: string  // Should be "1".
+	OriginalMailFrom: string  // SMTP MAIL FROM of reported message.
+	OriginalRcptTo?: string[] | null  // SMTP RCPT TO of reported message.
+	ArrivalDate: string  // In message date-time format.
+	SourceIP: string  // IP of the sending mail server.
+	ReportedDomain: string  // Domain the report is about, the message From domain.
+	AuthenticationResults?: string[] | null
+	AuthFailure: string  // E.g. "dmarc", "dkim", "spf".
+	IdentityAlignment: string  // Mechanisms that passed with alignment, "none", or e.g. "dkim, spf".
+	DeliveryResult: string  // "delivered", "spam", "policy", "reject", "other".
+	DKIMDomain: string
+	DKIMIdentity: string
+	DKIMSelector: string
+	SPFDNS: string
+	Header: string  // Header of the reported message, possibly redacted. Some reporters include the full message, we only keep the header.
+}
+
 // DMARCSummary presents DMARC aggregate reporting statistics for a single domain
 // over a period.
 export interface DMARCSummary {
@@ -625,11 +938,353 @@ export interface DMARCSummary {
 	PolicyOverrides?: { [key: string]: number }
 }
 
+// Analytics summarizes the aggregate reports for a domain over a period.
+// 
+// Message counts are as reported by the reporting organizations. A message
+// fails DMARC if neither DKIM nor SPF passed with alignment.
+export interface Analytics {
+	Domain: string  // Empty for all domains.
+	Reports: number
+	Total: number
+	Pass: number
+	Fail: number
+	DKIMAlignedPass: number  // DKIM pass with alignment, as evaluated for the DMARC policy.
+	SPFAlignedPass: number  // SPF pass with alignment.
+	DKIMAuthPass: number  // At least one DKIM signature passed, aligned or not.
+	SPFAuthPass: number  // SPF passed, aligned or not.
+	Quarantine: number  // Disposition of messages.
+	Reject: number
+	Days?: AnalyticsDay[] | null  // Sorted by day, for trends.
+	Sources?: AnalyticsSource[] | null  // Most messages first.
+	FailingSources?: AnalyticsSource[] | null  // Most failures first, only sources with failures.
+	Reporters?: AnalyticsReporter[] | null  // Most messages first.
+}
+
+// AnalyticsDay holds message counts for reports with a period starting on a day.
+export interface AnalyticsDay {
+	Day: string  // In UTC, "2006-01-02".
+	Total: number
+	Fail: number
+	DKIMFail: number
+	SPFFail: number
+}
+
+// AnalyticsSource holds message counts for a sending IP.
+export interface AnalyticsSource {
+	IP: string
+	Total: number
+	Fail: number
+	DKIMFail: number
+	SPFFail: number
+	Domains?: string[] | null  // Domains from message From headers.
+}
+
+// AnalyticsReporter holds message counts for an organization sending reports.
+export interface AnalyticsReporter {
+	OrgName: string
+	Reports: number
+	Total: number
+	Fail: number
+}
+
+// PostmasterData holds reputation data fetched from mailbox providers, and our
+// outgoing delivery volume to those providers, for a period.
+export interface PostmasterData {
+	SNDS?: SNDSRecord[] | null
+	Google?: GoogleStats[] | null
+	Volumes?: Volume[] | null
+}
+
+// SNDSRecord holds the Microsoft SNDS data for one IP and activity period
+// (typically a day).
+export interface SNDSRecord {
+	ID: number
+	IP: string
+	ActivityStart: Date
+	ActivityEnd: Date
+	RcptCommands: number
+	DataCommands: number
+	MessageRecipients: number
+	FilterResult: string  // GREEN, YELLOW or RED, the fraction of messages marked as spam.
+	ComplaintRate: string  // E.g. "< 0.1%".
+	TrapPeriodStart: Date
+	TrapPeriodEnd: Date
+	TrapHits: number
+	SampleHELO: string
+	SampleMailFrom: string
+	Comments: string
+	Updated: Date
+}
+
+// GoogleStats holds the Google Postmaster Tools traffic statistics for one
+// domain and day.
+export interface GoogleStats {
+	ID: number
+	Domain: string  // As registered at Google, lower case.
+	DayUTC: string  // Of the form yyyymmdd.
+	UserReportedSpamRatio: number
+	DomainReputation: string  // HIGH, MEDIUM, LOW, BAD, or empty.
+	IPReputations?: GoogleIPReputation[] | null
+	SPFSuccessRatio: number
+	DKIMSuccessRatio: number
+	DMARCSuccessRatio: number
+	OutboundEncryptionRatio: number
+	DeliveryErrors?: GoogleDeliveryError[] | null
+	Updated: Date
+}
+
+// GoogleIPReputation is the number of our IPs with a reputation.
+export interface GoogleIPReputation {
+	Reputation: string  // HIGH, MEDIUM, LOW or BAD.
+	IPCount: number
+	SampleIPs?: string[] | null
+}
+
+// GoogleDeliveryError is the ratio of messages rejected or temporarily failed
+// for a class and type of error.
+export interface GoogleDeliveryError {
+	ErrorClass: string  // PERMANENT_ERROR or TEMPORARY_ERROR.
+	ErrorType: string  // E.g. RATE_LIMIT_EXCEEDED, SUSPECTED_SPAM, DMARC_POLICY.
+	ErrorRatio: number
+}
+
+// Volume is the number of our outgoing deliveries to a provider for a day and
+// sender domain.
+export interface Volume {
+	DayUTC: string  // Of the form yyyymmdd.
+	Provider: string  // "google" or "microsoft".
+	SenderDomain: string  // Unicode.
+	Delivered: number
+	Failed: number
+}
+
+// Sender is the aggregated reputation of a sending domain or IP.
+export interface Sender {
+	ID: number
+	Type: SenderType
+	Value: string
+	First: Date
+	Last: Date  // Last delivery attempt.
+	Accepted: number  // Delivery attempts. A single delivery attempt can be for multiple recipients. For domains, only deliveries with a validated message From address are counted as accepted or rejected.; Delivered to at least one recipient.
+	Rejected: number  // Refused for at least one recipient, e.g. as junk or for policy.
+	UnknownRecipients: number  // Recipients refused because they do not exist, a bounce in response.
+	SPFPass: number  // Authentication results of delivery attempts.
+	DKIMPass: number  // At least one valid DKIM signature.
+	DMARCPass: number
+	DMARCFail: number
+	Junk: number  // Messages marked as junk or not junk by users, after delivery. For domains, only for messages with a validated message From address.
+	Notjunk: number
+	Spamtraps: number  // Messages sent to spamtrap addresses. For domains, only for messages with a validated From address.
+	Override: Override
+	OverrideUpdated: Date  // Time of last change to Override.
+}
+
 // Reverse is the result of a reverse lookup.
 export interface Reverse {
 	Hostnames?: string[] | null
 }
 
+// Analysis is the result of fully expanding the SPF record of a domain, following
+// includes and redirects, for diagnostics. Unlike evaluation, analysis does not
+// stop at the limit of DNS lookups, so the total number of lookups can be
+// reported.
+export interface Analysis {
+	Domain: Domain
+	Record?: AnalysisRecord | null  // Record for Domain, with nested records for includes and redirect.
+	DNSLookups: number  // Number of DNS lookups for mechanisms and modifiers that count against the limit of 10, regardless of the IP that is evaluated. A "p" macro adds a lookup during evaluation too.
+	VoidLookups: number  // Number of lookups that resulted in no records, evaluation fails with more than 2.
+	Authentic: boolean  // Whether all DNS responses were DNSSEC-verified.
+	Errors?: string[] | null  // Problems that cause evaluation to result in a permerror or temperror, e.g. exceeding the lookup limits, loops or missing included records.
+	Warnings?: string[] | null  // Potential problems, e.g. IP networks authorized multiple times, mechanisms that are never evaluated, or deprecated mechanisms.
+}
+
+// AnalysisRecord is the SPF record for a domain, as found during analysis.
+export interface AnalysisRecord {
+	Domain: string  // Domain for the record, after macro expansion.
+	TXT: string  // As found in DNS, can be empty if not found.
+	Error: string  // Error looking up or parsing the record.
+	Mechanisms?: AnalysisMechanism[] | null
+}
+
+// AnalysisMechanism describes a directive or redirect modifier in a record.
+export interface AnalysisMechanism {
+	Mechanism: string  // E.g. "-all", "include:example.com", or "redirect=example.com".
+	Expanded: string  // Domain after macro expansion, if the mechanism had macros.
+	DNSLookups: number  // Lookups counting against the limit, 0 or 1.
+	Void: boolean  // Whether a lookup resulted in no records.
+	IPs?: string[] | null
+	Error: string
+	Include?: AnalysisRecord | null  // For "include" and "redirect".
+}
+
+// DANEHost is the status of the DANE TLSA records of the mail host, compared
+// against the keys of the current and next TLS certificates.
+export interface DANEHost {
+	Host: string  // Host name of the public listener.
+	Name: string  // DNS name of the TLSA records, e.g. _25._tcp.mail.example.com.
+	Authentic: boolean  // Whether the TLSA lookup was DNSSEC-verified. DANE is only in effect if so.
+	Keys?: DANEKey[] | null
+	Published?: string[] | null  // TLSA records found in DNS.
+	Records?: string[] | null  // Suggested TLSA records: for keys of current certificates and configured keys.
+	Errors?: string[] | null
+	Warnings?: string[] | null
+}
+
+// DANEKey is a TLS key of the mail host with its DANE TLSA record, for a current
+// certificate, for new certificates, or configured in HostPrivateKeyFiles.
+export interface DANEKey {
+	KeyType: string  // E.g. "ecdsa-p256" or "rsa-2048".
+	TLSA: string  // TLSA record data: DANE-EE, SPKI, SHA2-256 hash.
+	Current: boolean  // Key of a certificate currently in use for the host.
+	Expires?: Date | null  // Expiration of the current certificate.
+	Next: boolean  // Key used when requesting new certificates through ACME.
+	Configured: boolean  // Key is in HostPrivateKeyFiles.
+	Published: boolean  // Whether a TLSA record for the key was found in DNS.
+}
+
+// AccountImportResult is the result for an account from AccountsImport.
+export interface AccountImportResult {
+	Name: string
+	Address: string
+	Password: string  // Initial password, generated if not specified. Empty for dry runs.
+	Error: string  // If non-empty, the account was not valid, and no accounts were added.
+}
+
+// SendSuspension is set when sending was suspended for the account, after
+// detecting an anomaly in submitted messages. Submissions are rejected until an
+// admin resumes sending.
+export interface SendSuspension {
+	ID: number  // Singleton ID 1.
+	Suspended: Date
+	Reason: string
+}
+
+// MessageStorage holds the sizes of messages in an account.
+export interface MessageStorage {
+	Messages: number  // Number of messages, excluding expunged messages.
+	Compressed: number  // Number of messages stored compressed.
+	Size: number  // Total uncompressed size of messages.
+	StoredSize: number  // Total size of messages as stored, including the message prefixes in the database.
+}
+
+// JunkFilterStats are training statistics for the junk filter of an account.
+export interface JunkFilterStats {
+	Account: JunkStats
+	Shared?: JunkStats | null  // Shared junk filter of the domain of the account, nil if not configured.
+}
+
+// Stats holds the number of trained messages and known words of a filter.
+export interface JunkStats {
+	Hams: number  // Number of messages trained as ham.
+	Spams: number  // Number of messages trained as spam.
+	Words: number  // Number of words (or word combinations) in the database.
+}
+
+// JunkVerdict is a message delivered over SMTP with the outcome of its junk
+// analysis.
+export interface JunkVerdict {
+	MessageID: number
+	Received: Date
+	Mailbox: string  // Current mailbox of the message.
+	From: string  // Address from message From header, can be empty.
+	Subject: string
+	Junk: boolean  // Current junk flag, e.g. after being marked by the user.
+	Notjunk: boolean
+	Verdict: MessageJunkVerdict
+}
+
+// MessageJunkVerdict is the outcome of the junk analysis during delivery over
+// SMTP, with the signals that contributed to it. Stored with the message to
+// explain why it was delivered to the Junk or Rejects mailbox, or accepted.
+export interface MessageJunkVerdict {
+	Accept: boolean  // Whether the analysis accepted the message. Rejected messages are stored in the Rejects mailbox.
+	Reason: string  // Reason for the decision, as in the X-Mox-Reason header, e.g. "junk-content", "dmarc-policy", "no-bad-signals" or a reputation method like "msgfromfull".
+	Details?: string[] | null  // Human-readable descriptions of the evaluated signals.
+	Mailbox: string  // Mailbox selected by the analysis, e.g. Junk for quarantined messages. Empty for the mailbox of the destination.
+	Classified: boolean  // Whether the junk filter classified the message, not done for senders with a conclusive reputation. The fields below are only set if so.
+	Score: number  // Spam probability, between 0 (ham) and 1 (spam). Combined with the shared junk filter of the domain, if configured.
+	Threshold: number  // Messages with a higher score are junk. Can be stricter than configured, e.g. for connections without TLS.
+	Significant: boolean  // Whether enough known words were found for the score to be used.
+	HamWords?: MessageJunkWord[] | null
+	SpamWords?: MessageJunkWord[] | null
+}
+
+// MessageJunkWord is a word (or word combination) that contributed to the score
+// of the junk filter.
+export interface MessageJunkWord {
+	Word: string
+	Score: number  // Spaminess, 0 is ham, 1 is spam.
+}
+
+// Passkey is a WebAuthn public key credential for logging into the web interfaces
+// without password, or as second factor after the password.
+export interface Passkey {
+	CredentialID: string  // Raw-url-base64-encoded credential ID, as chosen by the authenticator.
+	Created: Date
+	Name: string  // Descriptive name to identify the passkey, e.g. the device or password manager.
+	Account: string  // Account the passkey authenticates. Empty for passkeys of the admin.
+	LoginAddress: string  // Login address for sessions, the address used when the passkey was registered. Empty for admin.
+	SignCount: number  // Signature counter of authenticator, zero if not implemented.
+	LastUsed: Date  // Zero if not used.
+}
+
+// TOTPSetup has the parameters for configuring an authenticator app.
+export interface TOTPSetup {
+	Secret: string  // Base32, for manual entry.
+	URL: string  // otpauth URL, as in the QR code.
+	QRCodePNG: string  // Data URL with PNG image of QR code.
+}
+
+// PasskeyCreateOptions are the parameters for navigator.credentials.create in
+// the browser, for registering a new passkey.
+export interface PasskeyCreateOptions {
+	Challenge?: string | null
+	RPID: string
+	RPName: string
+	UserID?: string | null
+	UserName: string
+	Algorithms?: number[] | null  // COSE algorithm identifiers.
+	ExcludeCredentials?: (string | null)[] | null  // Already registered, the browser won't register a second passkey on the same authenticator.
+	Timeout: number  // In milliseconds.
+}
+
+// AdminUser is a named user for the admin web interface.
+export interface AdminUser {
+	Role: string
+	Domains?: string[] | null
+}
+
+// SignupRequest is a request for a new account through self-service signup.
+// Unverified requests are removed after 24 hours.
+export interface SignupRequest {
+	ID: number
+	Created: Date
+	State: SignupState
+	Account: string  // Name of the account to create.
+	Address: string  // Initial address of the account.
+	FullName: string
+	ContactEmail: string  // External address, verified before the account is created.
+	InviteID: number  // Zero for open signups.
+	RemoteIP: string
+	Verified: Date  // When the contact email address was verified.
+	Decided: Date  // When the request was approved or rejected.
+	DecidedBy: string  // Admin, or empty for automatic approval.
+	RejectReason: string
+}
+
+// SignupInvite is an invitation for self-service signup of an account, created
+// by an admin. Only a hash of the token is stored.
+export interface SignupInvite {
+	ID: number
+	Created: Date
+	Domain: string  // Domain for the address of new accounts.
+	Expires: Date
+	MaxUses: number  // Number of signups that can use the invite.
+	Uses: number  // Number of signups that used the invite, incremented when verified.
+	Note: string  // For admins, e.g. who the invite was sent to.
+	CreatedBy: string  // Admin that created the invite.
+}
+
 // ClientConfigs holds the client configuration for IMAP/Submission for a
 // domain.
 export interface ClientConfigs {
@@ -671,6 +1326,8 @@ export interface Filter {
 	Submitted: string  // Whether submitted before/after a time relative to now. ">$duration" or "<$duration", also with "now" for duration.
 	NextAttempt: string  // ">$duration" or "<$duration", also with "now" for duration.
 	Transport?: string | null
+	Domain: string  // Recipient domain. If it starts with a dot, subdomains also match.
+	LastError: string  // Substring of the error of the last delivery attempt, case-insensitive.
 }
 
 export interface Sort {
@@ -689,6 +1346,7 @@ export interface Msg {
 	BaseID: number  // A message for multiple recipients will get a BaseID that is identical to the first Msg.ID queued. The message contents will be identical for each recipient, including MsgPrefix. If other properties are identical too, including recipient domain, multiple Msgs may be delivered in a single SMTP transaction. For messages with a single recipient, this field will be 0.
 	Queued: Date
 	Hold: boolean  // If set, delivery won't be attempted.
+	HoldManual: boolean  // If set, Hold was set explicitly for the message, not by a hold rule. Resuming a hold rule doesn't take the message off hold.
 	SenderAccount: string  // Failures are delivered back to this local account. Also used for routing.
 	SenderLocalpart: Localpart  // Should be a local user and domain.
 	SenderDomain: IPDomain
@@ -707,6 +1365,7 @@ export interface Msg {
 	SMTPUTF8: boolean  // Whether message requires use of SMTPUTF8.
 	IsDMARCReport: boolean  // Delivery failures for DMARC reports are handled differently.
 	IsTLSReport: boolean  // Delivery failures for TLS reports are handled differently.
+	IsListMessage: boolean  // Delivery failures for mailing list messages are handled by the list.
 	Size: number  // Full size of message, combined MsgPrefix with contents of message file.
 	MessageID: string  // Message-ID header, including <>. Used when composing a DSN, in its References header.
 	MsgPrefix?: string | null  // Data to send before the contents from the file, typically with headers like DKIM-Signature.
@@ -715,7 +1374,9 @@ export interface Msg {
 	Transport: string  // If non-empty, the transport to use for this message. Can be set through cli or admin interface. If empty (the default for a submitted message), regular routing rules apply.
 	RequireTLS?: boolean | null  // RequireTLS influences TLS verification during delivery.  If nil, the recipient domain policy is followed (MTA-STS and/or DANE), falling back to optional opportunistic non-verified STARTTLS.  If RequireTLS is true (through SMTP REQUIRETLS extension or webmail submit), MTA-STS or DANE is required, as well as REQUIRETLS support by the next hop server.  If RequireTLS is false (through messag header "TLS-Required: No"), the recipient domain's policy is ignored if it does not lead to a successful TLS connection, i.e. falling back to SMTP delivery with unverified STARTTLS or plain text.
 	FutureReleaseRequest: string  // For DSNs, where the original FUTURERELEASE value must be included as per-message field. This field should be of the form "for;" plus interval, or "until;" plus utc date-time.
+	Priority: number  // Priority of the message, from -9 to 9, 0 being normal priority. Set through the SMTP MT-PRIORITY extension (RFC 6710). Messages with a higher priority are delivered first when multiple messages are due, and the priority is passed on to remote servers that support MT-PRIORITY.
 	Extra?: { [key: string]: string }  // Extra information, for transactional email.
+	Changes?: MsgChange[] | null  // Changes made by admin to envelope or message, as audit trail.
 }
 
 // IPDomain is an ip address, a domain, or empty.
@@ -732,6 +1393,38 @@ export interface MsgResult {
 	Code: number
 	Secode: string
 	Error: string
+	RemoteMTA: string  // Host name and IP address of remote server.
+	Response?: string[] | null  // Full response from remote server, one or more lines.
+	TLS: string  // TLS version and cipher suite, empty if TLS was not used.
+	MTASTS: boolean  // Whether TLS was verified with MTA-STS policy.
+	DANE: boolean  // Whether TLS was verified with DANE.
+	Transcript: string  // SMTP protocol transcript, without authentication and message data. Only kept for the most recent attempt.
+	DNSLookups?: Lookup[] | null  // DNS lookups made for the delivery attempt, with whether they were DNSSEC-verified. Only kept for the most recent attempt.
+	DANEStatus: string  // Why DANE was or was not used to verify TLS: "insecure" (destination not DNSSEC-verified), "dnssec-error", "no-tlsa", "unusable" (only unusable TLSA records, STARTTLS required but not verified), "required", "tlsa-error", "ignored" (due to TLS-Required: No message header) or "skipped" (for plaintext fallback). Empty for deliveries through a transport other than direct.
+}
+
+// Lookup is a DNS lookup made through a Recorder, with its DNSSEC status.
+export interface Lookup {
+	Type: string  // E.g. "mx", "ip", "cname", "tlsa".
+	Name: string  // Requested name. For TLSA, the full name, e.g. _25._tcp.<host>.
+	Authentic: boolean  // Whether the response, or the nonexistence of records, was DNSSEC-verified by the recursive resolver.
+	Error: string  // Error for the lookup, including "not found" errors.
+}
+
+// MsgChange is a change made by an admin to a message in the queue, kept with
+// the message as audit trail.
+export interface MsgChange {
+	Time: Date
+	Description: string  // E.g. "recipient changed from ... to ...".
+}
+
+// MsgRewrite describes changes to a message in the queue, to be used for its
+// next delivery attempt. Only non-nil fields are changed.
+export interface MsgRewrite {
+	Recipient?: string | null  // Address used in RCPT TO.
+	Transport?: string | null  // Empty for the default transport based on routes.
+	From?: string | null  // From header value, e.g. "Name <user@example.org>". When From or Subject is changed, DKIM-Signature header fields are removed and the message is signed again if the From domain has DKIM configured.
+	Subject?: string | null
 }
 
 // RetiredFilter filters messages to list or operate on. Used by admin web interface
@@ -781,19 +1474,87 @@ export interface MsgRetired {
 	SMTPUTF8: boolean  // Whether message requires use of SMTPUTF8.
 	IsDMARCReport: boolean  // Delivery failures for DMARC reports are handled differently.
 	IsTLSReport: boolean  // Delivery failures for TLS reports are handled differently.
+	IsListMessage: boolean  // Delivery failures for mailing list messages are handled by the list.
 	Size: number  // Full size of message, combined MsgPrefix with contents of message file.
 	MessageID: string  // Used when composing a DSN, in its References header.
 	Subject: string  // For context about delivery.
 	Transport: string
 	RequireTLS?: boolean | null
 	FutureReleaseRequest: string
+	Priority: number
 	Extra?: { [key: string]: string }  // Extra information, for transactional email.
+	Changes?: MsgChange[] | null  // Changes made by admin while in the queue.
 	LastActivity: Date
 	RecipientAddress: string
 	Success: boolean  // Whether delivery to next hop succeeded.
 	KeepUntil: Date
 }
 
+// DeadFilter filters messages in the dead-letter queue to list or operate on.
+// 
+// Only non-empty/non-zero values are applied to the filter. Leaving all fields
+// empty/zero matches all messages.
+export interface DeadFilter {
+	Max: number
+	IDs?: number[] | null
+	Account: string
+	From: string
+	To: string
+}
+
+// MsgDead is a message for which delivery failed permanently, kept in the
+// dead-letter queue along with its message file, if configured with
+// QueueDeadLetterPeriod in mox.conf. The admin can inspect the delivery results,
+// export the message, change the recipient and requeue the message for delivery.
+export interface MsgDead {
+	ID: number  // Same ID as it was as Msg.ID.
+	Queued: Date
+	SenderAccount: string
+	SenderLocalpart: Localpart
+	SenderDomain: IPDomain
+	SenderDomainStr: string  // For filtering, unicode.
+	FromID: string
+	RecipientLocalpart: Localpart
+	RecipientDomain: IPDomain
+	RecipientDomainStr: string  // For filtering, unicode.
+	Attempts: number
+	MaxAttempts: number
+	DialedIPs?: { [key: string]: IP[] | null }
+	LastAttempt?: Date | null
+	Results?: MsgResult[] | null  // Results of all delivery attempts, the last one being the permanent failure.
+	Has8bit: boolean
+	SMTPUTF8: boolean
+	Size: number
+	MessageID: string
+	MsgPrefix?: string | null
+	Subject: string
+	Transport: string
+	RequireTLS?: boolean | null
+	FutureReleaseRequest: string
+	Priority: number
+	Extra?: { [key: string]: string }
+	Failed: Date  // Time the message was moved to the dead-letter queue.
+	KeepUntil: Date
+}
+
+// HostHealth tracks the outcome of delivery attempts to a remote host, typically
+// an MX host. Hosts with recent failures are put in a cooldown period, during
+// which deliveries are first attempted to other hosts with the same MX preference.
+export interface HostHealth {
+	Host: string  // ASCII domain name or IP address. Primary key.
+	LastAttempt: Date
+	LastSuccess: Date
+	LastFailure: Date
+	LastError: string
+	Attempts: number
+	Successes: number  // Connections with an SMTP transaction, also when the remote server refused delivery permanently.
+	ConnectFailures: number  // Connection could not be made, including failures to look up IPs.
+	TLSFailures: number  // STARTTLS or TLS verification failed.
+	TemporaryFailures: number  // 4xx responses, or connections that failed during the SMTP transaction.
+	ConsecutiveFailures: number
+	CooldownUntil: Date  // If in the future, other hosts with same MX preference are tried first.
+}
+
 // HookFilter filters messages to list or operate on. Used by admin web interface
 // and cli.
 // 
@@ -978,6 +1739,9 @@ export interface TransportSocks {
 export interface TransportDirect {
 	DisableIPv4: boolean
 	DisableIPv6: boolean
+	PreferIPFamily: string
+	HappyEyeballsDelay: number
+	DisableHappyEyeballs: boolean
 }
 
 // EvaluationStat summarizes stored evaluations, for inclusion in an upcoming
@@ -1055,10 +1819,106 @@ export interface Dynamic {
 	WebDomainRedirects?: { [key: string]: string }
 	WebHandlers?: WebHandler[] | null
 	Routes?: Route[] | null
+	RetrySchedules?: RetrySchedule[] | null
+	TLSPolicies?: TLSPolicy[] | null
 	MonitorDNSBLs?: string[] | null
 	MonitorDNSBLZones?: Domain[] | null
 }
 
+// RetrySchedule specifies the intervals between delivery attempts for matching
+// messages in the queue, and when to give up.
+export interface RetrySchedule {
+	ToDomain?: string[] | null
+	Class: string
+	MinPriority: number
+	MaxPriority: number
+	Intervals?: number[] | null
+	MaxAttempts: number
+	MaxAge: number
+	ToDomainASCII?: string[] | null
+}
+
+// TLSPolicy specifies TLS requirements for direct delivery to matching recipient
+// domains.
+export interface TLSPolicy {
+	ToDomain?: string[] | null
+	Mode: string
+	CertificateSHA256?: string[] | null
+	ToDomainASCII?: string[] | null
+}
+
+// DynamicConfig is the dynamic config in structured form and as text in
+// domains.conf format, with a hash of the text to detect concurrent changes.
+// 
+// Admin users are left out. They are managed separately, and kept as is when
+// applying a config.
+export interface DynamicConfig {
+	Config: Dynamic
+	Text: string
+	Hash: string  // Hex SHA-256 of Text.
+}
+
+// DKIMRotationStatus is the state of automatic DKIM key rotation for a domain.
+export interface DKIMRotationStatus {
+	Config?: DKIMRotation | null  // Nil if rotation is not configured.
+	Provisioned: boolean  // Whether DNS records are managed through DNS provisioning.
+	Rotated: Date  // Previous rotation completed, or rotation enabled. Zero if rotation has not started.
+	Added: Date  // New selectors added, zero if no rotation in progress.
+	Switched: Date  // Signing switched to new selectors, zero if not yet.
+	Old?: string[] | null  // Selectors being replaced.
+	New?: string[] | null  // Selectors added, in order of Old.
+	NextStage: string  // Next stage of the rotation, "add", "switch" or "remove", and when it is due. The switch is delayed until the new DNS records are found.
+	Next: Date
+	Tasks?: DKIMRotationTask[] | null  // DNS records the administrator must add or remove, for domains without DNS provisioning.
+	History?: DKIMRotationEvent[] | null  // Most recent first.
+}
+
+// DKIMRotationTask is a DNS change the administrator must make for DKIM key
+// rotation.
+export interface DKIMRotationTask {
+	Action: string  // "add" or "remove".
+	Name: string  // E.g. "20240101a._domainkey.example.com".
+	Value: string  // TXT record value for action "add".
+}
+
+// DKIMRotationEvent is an entry in the history of DKIM key rotations for a
+// domain.
+export interface DKIMRotationEvent {
+	ID: number
+	Domain: string  // Unicode name.
+	Time: Date
+	Kind: string  // "started" when rotation was enabled, "added" for new selectors, "switched" for signing with new selectors, "removed" for removed old selectors, "recordsremoved" when the administrator removed DNS records.
+	Selectors?: string[] | null
+	Text: string  // Details, e.g. about DNS records to add or remove.
+}
+
+// Subscriber is an address subscribed to a list.
+export interface Subscriber {
+	ID: number
+	List: string  // Canonical list address, e.g. list@example.com.
+	Address: string  // Subscribed address.
+	Token: string  // For confirming and managing the subscription, and matching delivery failures.
+	Confirmed: boolean  // Only confirmed subscribers get messages.
+	Digest: boolean  // Receive a daily digest instead of each message.
+	Bounces: number  // Number of delivery failures, reset after 30 days without failures.
+	LastBounce: Date  // Time of last delivery failure.
+	Created: Date
+}
+
+// Message is a message sent to a list, kept in the archive and/or for the next
+// digest.
+export interface Message {
+	ID: number
+	List: string
+	Received: Date
+	MessageID: string
+	From: string  // Address from message From header, before any rewriting.
+	Subject: string
+	Size: number
+	Archive: boolean  // Whether to keep the message after including it in a digest.
+	DigestPending: boolean  // Whether the message still has to be included in a digest.
+}
+
 // TLSPublicKey is a public key for use with TLS client authentication based on the
 // public key of the certificate.
 export interface TLSPublicKey {
@@ -1082,7 +1942,7 @@ export interface LoginAttempt {
 	Last: Date  // Last has an index for efficient removal of entries after 30 days.
 	First: Date
 	Count: number  // Number of login attempts for the combination of fields below.
-	AccountName: string  // Admin logins use "(admin)". If no account is known, "-" is used. AccountName has an index for efficiently removing failed login attempts at the end of the list when there are too many, and for efficiently removing all records for an account.
+	AccountName: string  // Admin logins use "(admin)", or "(admin:<user>)" for admin users. If no account is known, "-" is used. AccountName has an index for efficiently removing failed login attempts at the end of the list when there are too many, and for efficiently removing all records for an account.
 	LoginAddress: string  // Empty for attempts to login in as admin.
 	RemoteIP: string
 	LocalIP: string
@@ -1094,6 +1954,81 @@ export interface LoginAttempt {
 	Result: AuthResult
 }
 
+// AuditFilter filters audit events. Empty fields are ignored.
+export interface AuditFilter {
+	Kind: string
+	Account: string
+	Actor: string
+	Start?: Date | null  // Inclusive.
+	End?: Date | null  // Exclusive.
+}
+
+// AuditEvent is a security-relevant event, such as a login or configuration
+// change. Events are only added, and removed after the retention period
+// configured in mox.conf.
+export interface AuditEvent {
+	ID: number
+	Time: Date
+	Kind: string  // See AuditLogin and friends.
+	Account: string  // Account the event applies to, empty if none.
+	Actor: string  // Who caused the event: an account name, "(admin)", or "(admin:<user>)" for admin users, "(ctl)" for commands through the ctl socket, or empty for the system itself.
+	Protocol: string  // E.g. "imap", "submission", "webmail", "webaccount", "webadmin", "ctl".
+	RemoteIP: string
+	Action: string  // E.g. name of API method or ctl command, or authentication mechanism for logins.
+	Result: string  // "ok", or an error or reason for failure.
+	Details: string  // Additional information, e.g. login address.
+}
+
+// QuotaStatus is the storage use of an account relative to its quota.
+export interface QuotaStatus {
+	Account: string
+	MessageSize: number  // Total size of messages.
+	Quota: number  // Maximum total message size, 0 if no limit.
+	SoftLimit: number  // 0 if no soft limit.
+	Percent: number  // Of Quota, 0 if no limit.
+	GraceEnd: Date  // End of the grace period while over the soft limit. Zero if not over the soft limit.
+}
+
+// MTASTSRollout is the state of the staged rollout of the MTA-STS policy for a
+// domain, based on TLS reports from remote mail servers received in the past 30
+// days.
+export interface MTASTSRollout {
+	Policy?: MTASTS | null  // Currently configured policy, nil if none.
+	NextMode: string  // Mode the policy can be promoted to ("testing" or "enforce"), empty if none, e.g. when the policy is already enforced.
+	Ready: boolean  // Whether the policy can be promoted to NextMode now.
+	Reasons?: string[] | null  // Explanations for the current state, e.g. requirements not yet met.
+	TestingDays: number  // Days with TLS reports about the policy in mode testing.
+	TestingSuccess: number  // Successful sessions in TLS reports about the policy in mode testing.
+	TestingFailure: number  // Failed sessions in TLS reports about the policy in mode testing.
+	RecentFailure: number  // Failed sessions in TLS reports for the past 7 days.
+	FailureTypes?: { [key: string]: number }
+	LastReportPeriod: Date  // End of period of most recent report, zero if none.
+}
+
+// DomainReadiness is the checklist of DNS records and configuration needed for a
+// domain to send and receive email, for onboarding new domains. The checks run in
+// the background. Clients poll for results, each poll starts a new check if the
+// previous one is older than a minute.
+export interface DomainReadiness {
+	Domain: string
+	Checked: Date  // Zero if no check has completed yet.
+	Checking: boolean  // Whether a check is in progress.
+	Error: string  // Error for the last check, if any.
+	Ready: boolean  // Whether all checks completed without errors.
+	Done: number  // Number of items with status "ok".
+	Items?: ReadinessItem[] | null
+	Records?: string[] | null  // All DNS records for the domain, as also returned by DomainRecords.
+}
+
+// ReadinessItem is a check in the readiness checklist of a domain.
+export interface ReadinessItem {
+	Name: string  // E.g. "MX" or "DKIM".
+	Status: string  // "ok", "warning" or "error".
+	Errors?: string[] | null
+	Warnings?: string[] | null
+	Instructions?: string[] | null
+}
+
 export type CSRFToken = string
 
 // Policy as used in DMARC DNS record for "p=" or "sp=".
@@ -1127,6 +2062,49 @@ export enum Mode {
 // Localparts are in Unicode NFC.
 export type Localpart = string
 
+// FailureCategory groups TLS result types into the kind of problem they
+// indicate.
+export enum FailureCategory {
+	CategorySTARTTLS = "starttls",  // STARTTLS not offered/denied.
+	CategoryCertificate = "certificate",  // Certificate expired, untrusted or host mismatch.
+	CategoryDANE = "dane",  // TLSA records or DNSSEC invalid, DANE required.
+	CategoryMTASTS = "mtasts",  // MTA-STS policy fetch or validation failed.
+	CategoryOther = "other",
+}
+
+// SenderType indicates whether a Sender is a domain or IP.
+export enum SenderType {
+	SenderDomain = "domain",  // Organizational domain of message From address, unicode.
+	SenderIP = "ip",  // IPv4 address, or IPv6 /64 network.
+}
+
+// Override is an admin decision for a sender, taking precedence over the
+// reputation.
+export enum Override {
+	OverrideNone = "",
+	OverrideAllow = "allow",  // Always accept messages.
+	OverrideQuarantine = "quarantine",  // Always deliver to the Junk mailbox.
+}
+
+// Status is the result of an SPF verification.
+export enum Status {
+	StatusNone = "none",  // E.g. no DNS domain name in session, or no SPF record in DNS.
+	StatusNeutral = "neutral",  // Explicit statement that nothing is said about the IP, "?" qualifier. None and Neutral must be treated the same.
+	StatusPass = "pass",  // IP is authorized.
+	StatusFail = "fail",  // IP is exlicitly not authorized. "-" qualifier.
+	StatusSoftfail = "softfail",  // Weak statement that IP is probably not authorized, "~" qualifier.
+	StatusTemperror = "temperror",  // Trying again later may succeed, e.g. for temporary DNS lookup error.
+	StatusPermerror = "permerror",  // Error requiring some intervention to correct. E.g. invalid DNS record.
+}
+
+// SignupState is the state of a signup request.
+export enum SignupState {
+	SignupUnverified = "unverified",  // Contact email address not yet verified.
+	SignupPending = "pending",  // Verified, waiting for approval by an admin.
+	SignupApproved = "approved",  // Account was created.
+	SignupRejected = "rejected",  // Rejected by an admin.
+}
+
 // An IP is a single IP address, a slice of bytes.
 // Functions in this package accept either 4-byte (IPv4)
 // or 16-byte (IPv6) slices as input.
@@ -1147,14 +2125,21 @@ export enum AuthResult {
 	AuthBadChannelBinding = "badchanbind",
 	AuthBadProtocol = "badprotocol",
 	AuthLoginDisabled = "logindisabled",
+	AuthLoginLocked = "loginlocked",
+	AuthPasswordExpired = "passwordexpired",
+	AuthAppPasswordRequired = "apppasswordrequired",  // Account password used while two-factor authentication requires an app password.
+	AuthTOTPRequired = "totprequired",  // Valid password for web login, but second factor still needed.
+	AuthTwoFactorSetup = "twofactorsetup",  // Two-factor authentication required but not yet set up.
 	AuthError = "error",
 	AuthAborted = "aborted",
 }
 
-export const structTypes: {[typename: string]: boolean} = {"Account":true,"Address":true,"AddressAlias":true,"Alias":true,"AliasAddress":true,"AuthResults":true,"AutoconfCheckResult":true,"AutodiscoverCheckResult":true,"AutodiscoverSRV":true,"AutomaticJunkFlags":true,"Canonicalization":true,"CheckResult":true,"ClientConfigs":true,"ClientConfigsEntry":true,"ConfigDomain":true,"DANECheckResult":true,"DKIM":true,"DKIMAuthResult":true,"DKIMCheckResult":true,"DKIMRecord":true,"DMARC":true,"DMARCCheckResult":true,"DMARCRecord":true,"DMARCSummary":true,"DNSSECResult":true,"DateRange":true,"Destination":true,"Directive":true,"Domain":true,"DomainFeedback":true,"Dynamic":true,"Evaluation":true,"EvaluationStat":true,"Extension":true,"FailureDetails":true,"Filter":true,"HoldRule":true,"Hook":true,"HookFilter":true,"HookResult":true,"HookRetired":true,"HookRetiredFilter":true,"HookRetiredSort":true,"HookSort":true,"IPDomain":true,"IPRevCheckResult":true,"Identifiers":true,"IncomingWebhook":true,"JunkFilter":true,"LoginAttempt":true,"MTASTS":true,"MTASTSCheckResult":true,"MTASTSRecord":true,"MX":true,"MXCheckResult":true,"Modifier":true,"Msg":true,"MsgResult":true,"MsgRetired":true,"OutgoingWebhook":true,"Pair":true,"Policy":true,"PolicyEvaluated":true,"PolicyOverrideReason":true,"PolicyPublished":true,"PolicyRecord":true,"Record":true,"Report":true,"ReportMetadata":true,"ReportRecord":true,"Result":true,"ResultPolicy":true,"RetiredFilter":true,"RetiredSort":true,"Reverse":true,"Route":true,"Row":true,"Ruleset":true,"SMTPAuth":true,"SPFAuthResult":true,"SPFCheckResult":true,"SPFRecord":true,"SRV":true,"SRVConfCheckResult":true,"STSMX":true,"Selector":true,"Sort":true,"SubjectPass":true,"Summary":true,"SuppressAddress":true,"TLSCheckResult":true,"TLSPublicKey":true,"TLSRPT":true,"TLSRPTCheckResult":true,"TLSRPTDateRange":true,"TLSRPTRecord":true,"TLSRPTSummary":true,"TLSRPTSuppressAddress":true,"TLSReportRecord":true,"TLSResult":true,"Transport":true,"TransportDirect":true,"TransportSMTP":true,"TransportSocks":true,"URI":true,"WebForward":true,"WebHandler":true,"WebInternal":true,"WebRedirect":true,"WebStatic":true,"WebserverConfig":true}
-export const stringsTypes: {[typename: string]: boolean} = {"Align":true,"AuthResult":true,"CSRFToken":true,"DMARCPolicy":true,"IP":true,"Localpart":true,"Mode":true,"RUA":true}
+export const structTypes: {[typename: string]: boolean} = {"Account":true,"AccountImportResult":true,"Address":true,"AddressAlias":true,"AdminUser":true,"Alias":true,"AliasAddress":true,"AliasList":true,"AliasMember":true,"Analysis":true,"AnalysisMechanism":true,"AnalysisRecord":true,"Analytics":true,"AnalyticsDay":true,"AnalyticsReporter":true,"AnalyticsSource":true,"AuditEvent":true,"AuditFilter":true,"AuthResults":true,"AutoconfCheckResult":true,"AutodiscoverCheckResult":true,"AutodiscoverSRV":true,"AutomaticJunkFlags":true,"BIMI":true,"Canonicalization":true,"Categories":true,"Category":true,"CheckResult":true,"ClientConfigs":true,"ClientConfigsEntry":true,"ConfigDomain":true,"DANECheckResult":true,"DANEHost":true,"DANEKey":true,"DKIM":true,"DKIMAuthResult":true,"DKIMCheckResult":true,"DKIMRecord":true,"DKIMRotation":true,"DKIMRotationEvent":true,"DKIMRotationStatus":true,"DKIMRotationTask":true,"DKIMSignRule":true,"DMARC":true,"DMARCCheckResult":true,"DMARCRecord":true,"DMARCSummary":true,"DNSProvisioning":true,"DNSSECResult":true,"DateRange":true,"DeadFilter":true,"Delegation":true,"Destination":true,"Directive":true,"Domain":true,"DomainFailureReport":true,"DomainFeedback":true,"DomainReadiness":true,"DomainSignup":true,"DomainWeb":true,"Dynamic":true,"DynamicConfig":true,"Evaluation":true,"EvaluationStat":true,"Extension":true,"FailureDetails":true,"FailureType":true,"Filter":true,"GoogleDeliveryError":true,"GoogleIPReputation":true,"GoogleStats":true,"HoldRule":true,"Hook":true,"HookFilter":true,"HookResult":true,"HookRetired":true,"HookRetiredFilter":true,"HookRetiredSort":true,"HookSort":true,"HostHealth":true,"IPDomain":true,"IPRevCheckResult":true,"Identifiers":true,"Identity":true,"IncomingWebhook":true,"JunkFilter":true,"JunkFilterStats":true,"JunkReevaluation":true,"JunkStats":true,"JunkVerdict":true,"LoginAttempt":true,"Lookup":true,"MTASTS":true,"MTASTSCheckResult":true,"MTASTSRecord":true,"MTASTSRollout":true,"MX":true,"MXCheckResult":true,"Message":true,"MessageJunkVerdict":true,"MessageJunkWord":true,"MessageStorage":true,"MessageTemplate":true,"Modifier":true,"Msg":true,"MsgChange":true,"MsgDead":true,"MsgResult":true,"MsgRetired":true,"MsgRewrite":true,"OutgoingFooter":true,"OutgoingHeaderRules":true,"OutgoingWebhook":true,"Pair":true,"Passkey":true,"PasskeyAssertion":true,"PasskeyCreateOptions":true,"PasskeyGetOptions":true,"PasswordPolicy":true,"Policy":true,"PolicyEvaluated":true,"PolicyOverrideReason":true,"PolicyPublished":true,"PolicyRecord":true,"PostmasterData":true,"QuotaStatus":true,"ReadinessItem":true,"Record":true,"Report":true,"ReportMetadata":true,"ReportRecord":true,"Result":true,"ResultPolicy":true,"RetiredFilter":true,"RetiredSort":true,"RetrySchedule":true,"Reverse":true,"Route":true,"Row":true,"Ruleset":true,"SMTPAuth":true,"SNDSRecord":true,"SPFAuthResult":true,"SPFCheckResult":true,"SPFRecord":true,"SRV":true,"SRVConfCheckResult":true,"STSMX":true,"Screening":true,"Selector":true,"SendSuspension":true,"Sender":true,"SharedJunkFilter":true,"SignupInvite":true,"SignupRequest":true,"Sort":true,"SpamActions":true,"Stats":true,"StatsCategory":true,"StatsDay":true,"StatsReporter":true,"StatsResultType":true,"SubjectPass":true,"Subscriber":true,"Summary":true,"SuppressAddress":true,"TLSCheckResult":true,"TLSPolicy":true,"TLSPublicKey":true,"TLSRPT":true,"TLSRPTCheckResult":true,"TLSRPTDateRange":true,"TLSRPTRecord":true,"TLSRPTSummary":true,"TLSRPTSuppressAddress":true,"TLSReportRecord":true,"TLSResult":true,"TOTPSetup":true,"Transport":true,"TransportDirect":true,"TransportSMTP":true,"TransportSocks":true,"URI":true,"Volume":true,"WebForward":true,"WebHandler":true,"WebInternal":true,"WebRedirect":true,"WebStatic":true,"WebmailDefaults":true,"WebserverConfig":true}
+export const stringsTypes: {[typename: string]: boolean} = {"Align":true,"AuthResult":true,"CSRFToken":true,"DMARCPolicy":true,"FailureCategory":true,"IP":true,"Localpart":true,"Mode":true,"Override":true,"RUA":true,"SenderType":true,"SignupState":true,"Status":true}
 export const intsTypes: {[typename: string]: boolean} = {}
 export const types: TypenameMap = {
+	"PasskeyGetOptions": {"Name":"PasskeyGetOptions","Docs":"","Fields":[{"Name":"Challenge","Docs":"","Typewords":["nullable","string"]},{"Name":"RPID","Docs":"","Typewords":["string"]},{"Name":"Timeout","Docs":"","Typewords":["int32"]}]},
+	"PasskeyAssertion": {"Name":"PasskeyAssertion","Docs":"","Fields":[{"Name":"CredentialID","Docs":"","Typewords":["nullable","string"]},{"Name":"ClientDataJSON","Docs":"","Typewords":["nullable","string"]},{"Name":"AuthenticatorData","Docs":"","Typewords":["nullable","string"]},{"Name":"Signature","Docs":"","Typewords":["nullable","string"]}]},
 	"CheckResult": {"Name":"CheckResult","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"DNSSEC","Docs":"","Typewords":["DNSSECResult"]},{"Name":"IPRev","Docs":"","Typewords":["IPRevCheckResult"]},{"Name":"MX","Docs":"","Typewords":["MXCheckResult"]},{"Name":"TLS","Docs":"","Typewords":["TLSCheckResult"]},{"Name":"DANE","Docs":"","Typewords":["DANECheckResult"]},{"Name":"SPF","Docs":"","Typewords":["SPFCheckResult"]},{"Name":"DKIM","Docs":"","Typewords":["DKIMCheckResult"]},{"Name":"DMARC","Docs":"","Typewords":["DMARCCheckResult"]},{"Name":"HostTLSRPT","Docs":"","Typewords":["TLSRPTCheckResult"]},{"Name":"DomainTLSRPT","Docs":"","Typewords":["TLSRPTCheckResult"]},{"Name":"MTASTS","Docs":"","Typewords":["MTASTSCheckResult"]},{"Name":"SRVConf","Docs":"","Typewords":["SRVConfCheckResult"]},{"Name":"Autoconf","Docs":"","Typewords":["AutoconfCheckResult"]},{"Name":"Autodiscover","Docs":"","Typewords":["AutodiscoverCheckResult"]}]},
 	"DNSSECResult": {"Name":"DNSSECResult","Docs":"","Fields":[{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]},{"Name":"Instructions","Docs":"","Typewords":["[]","string"]}]},
 	"IPRevCheckResult": {"Name":"IPRevCheckResult","Docs":"","Fields":[{"Name":"Hostname","Docs":"","Typewords":["Domain"]},{"Name":"IPNames","Docs":"","Typewords":["{}","[]","string"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]},{"Name":"Instructions","Docs":"","Typewords":["[]","string"]}]},
@@ -1186,26 +2171,47 @@ export const types: TypenameMap = {
 	"AutoconfCheckResult": {"Name":"AutoconfCheckResult","Docs":"","Fields":[{"Name":"ClientSettingsDomainIPs","Docs":"","Typewords":["[]","string"]},{"Name":"IPs","Docs":"","Typewords":["[]","string"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]},{"Name":"Instructions","Docs":"","Typewords":["[]","string"]}]},
 	"AutodiscoverCheckResult": {"Name":"AutodiscoverCheckResult","Docs":"","Fields":[{"Name":"Records","Docs":"","Typewords":["[]","AutodiscoverSRV"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]},{"Name":"Instructions","Docs":"","Typewords":["[]","string"]}]},
 	"AutodiscoverSRV": {"Name":"AutodiscoverSRV","Docs":"","Fields":[{"Name":"Target","Docs":"","Typewords":["string"]},{"Name":"Port","Docs":"","Typewords":["uint16"]},{"Name":"Priority","Docs":"","Typewords":["uint16"]},{"Name":"Weight","Docs":"","Typewords":["uint16"]},{"Name":"IPs","Docs":"","Typewords":["[]","string"]}]},
-	"ConfigDomain": {"Name":"ConfigDomain","Docs":"","Fields":[{"Name":"Disabled","Docs":"","Typewords":["bool"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"ClientSettingsDomain","Docs":"","Typewords":["string"]},{"Name":"LocalpartCatchallSeparator","Docs":"","Typewords":["string"]},{"Name":"LocalpartCatchallSeparators","Docs":"","Typewords":["[]","string"]},{"Name":"LocalpartCaseSensitive","Docs":"","Typewords":["bool"]},{"Name":"DKIM","Docs":"","Typewords":["DKIM"]},{"Name":"DMARC","Docs":"","Typewords":["nullable","DMARC"]},{"Name":"MTASTS","Docs":"","Typewords":["nullable","MTASTS"]},{"Name":"TLSRPT","Docs":"","Typewords":["nullable","TLSRPT"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"Aliases","Docs":"","Typewords":["{}","Alias"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"LocalpartCatchallSeparatorsEffective","Docs":"","Typewords":["[]","string"]}]},
-	"DKIM": {"Name":"DKIM","Docs":"","Fields":[{"Name":"Selectors","Docs":"","Typewords":["{}","Selector"]},{"Name":"Sign","Docs":"","Typewords":["[]","string"]}]},
+	"ConfigDomain": {"Name":"ConfigDomain","Docs":"","Fields":[{"Name":"Disabled","Docs":"","Typewords":["bool"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"ClientSettingsDomain","Docs":"","Typewords":["string"]},{"Name":"LocalpartCatchallSeparator","Docs":"","Typewords":["string"]},{"Name":"LocalpartCatchallSeparators","Docs":"","Typewords":["[]","string"]},{"Name":"LocalpartCaseSensitive","Docs":"","Typewords":["bool"]},{"Name":"DKIM","Docs":"","Typewords":["DKIM"]},{"Name":"DMARC","Docs":"","Typewords":["nullable","DMARC"]},{"Name":"MTASTS","Docs":"","Typewords":["nullable","MTASTS"]},{"Name":"TLSRPT","Docs":"","Typewords":["nullable","TLSRPT"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"Aliases","Docs":"","Typewords":["{}","Alias"]},{"Name":"OutgoingHeaderRules","Docs":"","Typewords":["nullable","OutgoingHeaderRules"]},{"Name":"OutgoingFooter","Docs":"","Typewords":["nullable","OutgoingFooter"]},{"Name":"MessageTemplates","Docs":"","Typewords":["[]","MessageTemplate"]},{"Name":"RequireTwoFactor","Docs":"","Typewords":["bool"]},{"Name":"QuotaMessageSize","Docs":"","Typewords":["int64"]},{"Name":"DNSProvisioning","Docs":"","Typewords":["nullable","DNSProvisioning"]},{"Name":"Signup","Docs":"","Typewords":["nullable","DomainSignup"]},{"Name":"Web","Docs":"","Typewords":["nullable","DomainWeb"]},{"Name":"BIMI","Docs":"","Typewords":["nullable","BIMI"]},{"Name":"SpamActions","Docs":"","Typewords":["nullable","SpamActions"]},{"Name":"SharedJunkFilter","Docs":"","Typewords":["nullable","SharedJunkFilter"]},{"Name":"Spamtraps","Docs":"","Typewords":["[]","string"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"LocalpartCatchallSeparatorsEffective","Docs":"","Typewords":["[]","string"]}]},
+	"DKIM": {"Name":"DKIM","Docs":"","Fields":[{"Name":"Selectors","Docs":"","Typewords":["{}","Selector"]},{"Name":"Sign","Docs":"","Typewords":["[]","string"]},{"Name":"SignRules","Docs":"","Typewords":["[]","DKIMSignRule"]},{"Name":"Rotation","Docs":"","Typewords":["nullable","DKIMRotation"]}]},
 	"Selector": {"Name":"Selector","Docs":"","Fields":[{"Name":"Hash","Docs":"","Typewords":["string"]},{"Name":"HashEffective","Docs":"","Typewords":["string"]},{"Name":"Canonicalization","Docs":"","Typewords":["Canonicalization"]},{"Name":"Headers","Docs":"","Typewords":["[]","string"]},{"Name":"HeadersEffective","Docs":"","Typewords":["[]","string"]},{"Name":"DontSealHeaders","Docs":"","Typewords":["bool"]},{"Name":"Expiration","Docs":"","Typewords":["string"]},{"Name":"PrivateKeyFile","Docs":"","Typewords":["string"]},{"Name":"Algorithm","Docs":"","Typewords":["string"]}]},
 	"Canonicalization": {"Name":"Canonicalization","Docs":"","Fields":[{"Name":"HeaderRelaxed","Docs":"","Typewords":["bool"]},{"Name":"BodyRelaxed","Docs":"","Typewords":["bool"]}]},
-	"DMARC": {"Name":"DMARC","Docs":"","Fields":[{"Name":"Localpart","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"ParsedLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]}]},
+	"DKIMSignRule": {"Name":"DKIMSignRule","Docs":"","Fields":[{"Name":"MsgFromRegexp","Docs":"","Typewords":["string"]},{"Name":"HeadersRegexp","Docs":"","Typewords":["{}","string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Sign","Docs":"","Typewords":["[]","string"]},{"Name":"Comment","Docs":"","Typewords":["string"]}]},
+	"DKIMRotation": {"Name":"DKIMRotation","Docs":"","Fields":[{"Name":"Interval","Docs":"","Typewords":["int64"]},{"Name":"PublishDelay","Docs":"","Typewords":["int64"]},{"Name":"RetainPeriod","Docs":"","Typewords":["int64"]}]},
+	"DMARC": {"Name":"DMARC","Docs":"","Fields":[{"Name":"Localpart","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"FailureAlertPercentage","Docs":"","Typewords":["int32"]},{"Name":"ParsedLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]}]},
 	"MTASTS": {"Name":"MTASTS","Docs":"","Fields":[{"Name":"PolicyID","Docs":"","Typewords":["string"]},{"Name":"Mode","Docs":"","Typewords":["Mode"]},{"Name":"MaxAge","Docs":"","Typewords":["int64"]},{"Name":"MX","Docs":"","Typewords":["[]","string"]}]},
 	"TLSRPT": {"Name":"TLSRPT","Docs":"","Fields":[{"Name":"Localpart","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"ParsedLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]}]},
 	"Route": {"Name":"Route","Docs":"","Fields":[{"Name":"FromDomain","Docs":"","Typewords":["[]","string"]},{"Name":"ToDomain","Docs":"","Typewords":["[]","string"]},{"Name":"MinimumAttempts","Docs":"","Typewords":["int32"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"FromDomainASCII","Docs":"","Typewords":["[]","string"]},{"Name":"ToDomainASCII","Docs":"","Typewords":["[]","string"]}]},
-	"Alias": {"Name":"Alias","Docs":"","Fields":[{"Name":"Addresses","Docs":"","Typewords":["[]","string"]},{"Name":"PostPublic","Docs":"","Typewords":["bool"]},{"Name":"ListMembers","Docs":"","Typewords":["bool"]},{"Name":"AllowMsgFrom","Docs":"","Typewords":["bool"]},{"Name":"LocalpartStr","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"ParsedAddresses","Docs":"","Typewords":["[]","AliasAddress"]}]},
-	"AliasAddress": {"Name":"AliasAddress","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["Address"]},{"Name":"AccountName","Docs":"","Typewords":["string"]},{"Name":"Destination","Docs":"","Typewords":["Destination"]}]},
+	"Alias": {"Name":"Alias","Docs":"","Fields":[{"Name":"Addresses","Docs":"","Typewords":["[]","string"]},{"Name":"PostPublic","Docs":"","Typewords":["bool"]},{"Name":"ListMembers","Docs":"","Typewords":["bool"]},{"Name":"AllowMsgFrom","Docs":"","Typewords":["bool"]},{"Name":"PostPolicy","Docs":"","Typewords":["string"]},{"Name":"Owners","Docs":"","Typewords":["[]","string"]},{"Name":"ListHeaders","Docs":"","Typewords":["bool"]},{"Name":"Members","Docs":"","Typewords":["{}","AliasMember"]},{"Name":"List","Docs":"","Typewords":["nullable","AliasList"]},{"Name":"LocalpartStr","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"ParsedAddresses","Docs":"","Typewords":["[]","AliasAddress"]},{"Name":"ParsedOwners","Docs":"","Typewords":["[]","AliasAddress"]}]},
+	"AliasMember": {"Name":"AliasMember","Docs":"","Fields":[{"Name":"NoDelivery","Docs":"","Typewords":["bool"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]}]},
+	"AliasList": {"Name":"AliasList","Docs":"","Fields":[{"Name":"Subscribe","Docs":"","Typewords":["bool"]},{"Name":"Archive","Docs":"","Typewords":["bool"]},{"Name":"BounceLimit","Docs":"","Typewords":["int32"]}]},
+	"AliasAddress": {"Name":"AliasAddress","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["Address"]},{"Name":"AccountName","Docs":"","Typewords":["string"]},{"Name":"Destination","Docs":"","Typewords":["Destination"]},{"Name":"Member","Docs":"","Typewords":["AliasMember"]}]},
 	"Address": {"Name":"Address","Docs":"","Fields":[{"Name":"Localpart","Docs":"","Typewords":["Localpart"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]}]},
 	"Destination": {"Name":"Destination","Docs":"","Fields":[{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Rulesets","Docs":"","Typewords":["[]","Ruleset"]},{"Name":"SMTPError","Docs":"","Typewords":["string"]},{"Name":"MessageAuthRequiredSMTPError","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]}]},
 	"Ruleset": {"Name":"Ruleset","Docs":"","Fields":[{"Name":"SMTPMailFromRegexp","Docs":"","Typewords":["string"]},{"Name":"MsgFromRegexp","Docs":"","Typewords":["string"]},{"Name":"VerifiedDomain","Docs":"","Typewords":["string"]},{"Name":"HeadersRegexp","Docs":"","Typewords":["{}","string"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"ListAllowDomain","Docs":"","Typewords":["string"]},{"Name":"AcceptRejectsToMailbox","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Comment","Docs":"","Typewords":["string"]},{"Name":"VerifiedDNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"ListAllowDNSDomain","Docs":"","Typewords":["Domain"]}]},
-	"Account": {"Name":"Account","Docs":"","Fields":[{"Name":"OutgoingWebhook","Docs":"","Typewords":["nullable","OutgoingWebhook"]},{"Name":"IncomingWebhook","Docs":"","Typewords":["nullable","IncomingWebhook"]},{"Name":"FromIDLoginAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"KeepRetiredMessagePeriod","Docs":"","Typewords":["int64"]},{"Name":"KeepRetiredWebhookPeriod","Docs":"","Typewords":["int64"]},{"Name":"LoginDisabled","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]},{"Name":"Destinations","Docs":"","Typewords":["{}","Destination"]},{"Name":"SubjectPass","Docs":"","Typewords":["SubjectPass"]},{"Name":"QuotaMessageSize","Docs":"","Typewords":["int64"]},{"Name":"RejectsMailbox","Docs":"","Typewords":["string"]},{"Name":"KeepRejects","Docs":"","Typewords":["bool"]},{"Name":"AutomaticJunkFlags","Docs":"","Typewords":["AutomaticJunkFlags"]},{"Name":"JunkFilter","Docs":"","Typewords":["nullable","JunkFilter"]},{"Name":"MaxOutgoingMessagesPerDay","Docs":"","Typewords":["int32"]},{"Name":"MaxFirstTimeRecipientsPerDay","Docs":"","Typewords":["int32"]},{"Name":"NoFirstTimeSenderDelay","Docs":"","Typewords":["bool"]},{"Name":"NoCustomPassword","Docs":"","Typewords":["bool"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"Aliases","Docs":"","Typewords":["[]","AddressAlias"]}]},
-	"OutgoingWebhook": {"Name":"OutgoingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"Events","Docs":"","Typewords":["[]","string"]}]},
-	"IncomingWebhook": {"Name":"IncomingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]}]},
+	"OutgoingHeaderRules": {"Name":"OutgoingHeaderRules","Docs":"","Fields":[{"Name":"Remove","Docs":"","Typewords":["[]","string"]},{"Name":"FromDisplayName","Docs":"","Typewords":["string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Add","Docs":"","Typewords":["[]","string"]}]},
+	"OutgoingFooter": {"Name":"OutgoingFooter","Docs":"","Fields":[{"Name":"Text","Docs":"","Typewords":["[]","string"]},{"Name":"HTML","Docs":"","Typewords":["string"]}]},
+	"MessageTemplate": {"Name":"MessageTemplate","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Text","Docs":"","Typewords":["[]","string"]}]},
+	"DNSProvisioning": {"Name":"DNSProvisioning","Docs":"","Fields":[{"Name":"Provider","Docs":"","Typewords":["string"]},{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"TTL","Docs":"","Typewords":["int32"]},{"Name":"DKIMRotation","Docs":"","Typewords":["nullable","DKIMRotation"]}]},
+	"DomainSignup": {"Name":"DomainSignup","Docs":"","Fields":[{"Name":"Open","Docs":"","Typewords":["bool"]},{"Name":"RequireApproval","Docs":"","Typewords":["bool"]},{"Name":"QuotaMessageSize","Docs":"","Typewords":["int64"]}]},
+	"DomainWeb": {"Name":"DomainWeb","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"LogoFile","Docs":"","Typewords":["string"]},{"Name":"LoginText","Docs":"","Typewords":["string"]},{"Name":"Color","Docs":"","Typewords":["string"]},{"Name":"BackgroundColor","Docs":"","Typewords":["string"]},{"Name":"CSSFile","Docs":"","Typewords":["string"]},{"Name":"WebmailDefaults","Docs":"","Typewords":["nullable","WebmailDefaults"]},{"Name":"DisabledFeatures","Docs":"","Typewords":["[]","string"]}]},
+	"WebmailDefaults": {"Name":"WebmailDefaults","Docs":"","Fields":[{"Name":"ShowHTML","Docs":"","Typewords":["bool"]},{"Name":"ShowAddressSecurity","Docs":"","Typewords":["bool"]},{"Name":"NoShowShortcuts","Docs":"","Typewords":["bool"]},{"Name":"RemoteContentProxy","Docs":"","Typewords":["bool"]},{"Name":"UndoSendSeconds","Docs":"","Typewords":["int32"]}]},
+	"BIMI": {"Name":"BIMI","Docs":"","Fields":[{"Name":"Selector","Docs":"","Typewords":["string"]},{"Name":"LogoFile","Docs":"","Typewords":["string"]},{"Name":"LogoURL","Docs":"","Typewords":["string"]},{"Name":"AuthorityFile","Docs":"","Typewords":["string"]},{"Name":"AuthorityURL","Docs":"","Typewords":["string"]}]},
+	"SpamActions": {"Name":"SpamActions","Docs":"","Fields":[{"Name":"TagThreshold","Docs":"","Typewords":["float64"]},{"Name":"JunkThreshold","Docs":"","Typewords":["float64"]},{"Name":"HighAction","Docs":"","Typewords":["string"]}]},
+	"SharedJunkFilter": {"Name":"SharedJunkFilter","Docs":"","Fields":[{"Name":"Weight","Docs":"","Typewords":["float64"]},{"Name":"Onegrams","Docs":"","Typewords":["bool"]},{"Name":"Twograms","Docs":"","Typewords":["bool"]},{"Name":"Threegrams","Docs":"","Typewords":["bool"]},{"Name":"MaxPower","Docs":"","Typewords":["float64"]},{"Name":"TopWords","Docs":"","Typewords":["int32"]},{"Name":"IgnoreWords","Docs":"","Typewords":["float64"]},{"Name":"RareWords","Docs":"","Typewords":["int32"]}]},
+	"Account": {"Name":"Account","Docs":"","Fields":[{"Name":"OutgoingWebhook","Docs":"","Typewords":["nullable","OutgoingWebhook"]},{"Name":"IncomingWebhook","Docs":"","Typewords":["nullable","IncomingWebhook"]},{"Name":"FromIDLoginAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"KeepRetiredMessagePeriod","Docs":"","Typewords":["int64"]},{"Name":"KeepRetiredWebhookPeriod","Docs":"","Typewords":["int64"]},{"Name":"LoginDisabled","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]},{"Name":"Destinations","Docs":"","Typewords":["{}","Destination"]},{"Name":"SubjectPass","Docs":"","Typewords":["SubjectPass"]},{"Name":"QuotaMessageSize","Docs":"","Typewords":["int64"]},{"Name":"RejectsMailbox","Docs":"","Typewords":["string"]},{"Name":"KeepRejects","Docs":"","Typewords":["bool"]},{"Name":"AutomaticJunkFlags","Docs":"","Typewords":["AutomaticJunkFlags"]},{"Name":"JunkFilter","Docs":"","Typewords":["nullable","JunkFilter"]},{"Name":"JunkReevaluation","Docs":"","Typewords":["nullable","JunkReevaluation"]},{"Name":"Screening","Docs":"","Typewords":["nullable","Screening"]},{"Name":"Categories","Docs":"","Typewords":["nullable","Categories"]},{"Name":"MaxOutgoingMessagesPerDay","Docs":"","Typewords":["int32"]},{"Name":"MaxFirstTimeRecipientsPerDay","Docs":"","Typewords":["int32"]},{"Name":"NoFirstTimeSenderDelay","Docs":"","Typewords":["bool"]},{"Name":"NoCustomPassword","Docs":"","Typewords":["bool"]},{"Name":"PasswordPolicy","Docs":"","Typewords":["nullable","PasswordPolicy"]},{"Name":"RequireTwoFactor","Docs":"","Typewords":["bool"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"OutgoingHeaderRules","Docs":"","Typewords":["nullable","OutgoingHeaderRules"]},{"Name":"OutgoingFooter","Docs":"","Typewords":["nullable","OutgoingFooter"]},{"Name":"Identities","Docs":"","Typewords":["[]","Identity"]},{"Name":"Delegations","Docs":"","Typewords":["[]","Delegation"]},{"Name":"DNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"Aliases","Docs":"","Typewords":["[]","AddressAlias"]},{"Name":"OwnedAliases","Docs":"","Typewords":["[]","string"]}]},
+	"OutgoingWebhook": {"Name":"OutgoingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"Events","Docs":"","Typewords":["[]","string"]},{"Name":"SigningKey","Docs":"","Typewords":["string"]},{"Name":"Transcript","Docs":"","Typewords":["bool"]}]},
+	"IncomingWebhook": {"Name":"IncomingWebhook","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"SigningKey","Docs":"","Typewords":["string"]}]},
 	"SubjectPass": {"Name":"SubjectPass","Docs":"","Fields":[{"Name":"Period","Docs":"","Typewords":["int64"]}]},
 	"AutomaticJunkFlags": {"Name":"AutomaticJunkFlags","Docs":"","Fields":[{"Name":"Enabled","Docs":"","Typewords":["bool"]},{"Name":"JunkMailboxRegexp","Docs":"","Typewords":["string"]},{"Name":"NeutralMailboxRegexp","Docs":"","Typewords":["string"]},{"Name":"NotJunkMailboxRegexp","Docs":"","Typewords":["string"]}]},
-	"JunkFilter": {"Name":"JunkFilter","Docs":"","Fields":[{"Name":"Threshold","Docs":"","Typewords":["float64"]},{"Name":"Onegrams","Docs":"","Typewords":["bool"]},{"Name":"Twograms","Docs":"","Typewords":["bool"]},{"Name":"Threegrams","Docs":"","Typewords":["bool"]},{"Name":"MaxPower","Docs":"","Typewords":["float64"]},{"Name":"TopWords","Docs":"","Typewords":["int32"]},{"Name":"IgnoreWords","Docs":"","Typewords":["float64"]},{"Name":"RareWords","Docs":"","Typewords":["int32"]}]},
-	"AddressAlias": {"Name":"AddressAlias","Docs":"","Fields":[{"Name":"SubscriptionAddress","Docs":"","Typewords":["string"]},{"Name":"Alias","Docs":"","Typewords":["Alias"]},{"Name":"MemberAddresses","Docs":"","Typewords":["[]","string"]}]},
+	"JunkFilter": {"Name":"JunkFilter","Docs":"","Fields":[{"Name":"Threshold","Docs":"","Typewords":["float64"]},{"Name":"TagThreshold","Docs":"","Typewords":["float64"]},{"Name":"JunkThreshold","Docs":"","Typewords":["float64"]},{"Name":"HighAction","Docs":"","Typewords":["string"]},{"Name":"Onegrams","Docs":"","Typewords":["bool"]},{"Name":"Twograms","Docs":"","Typewords":["bool"]},{"Name":"Threegrams","Docs":"","Typewords":["bool"]},{"Name":"MaxPower","Docs":"","Typewords":["float64"]},{"Name":"TopWords","Docs":"","Typewords":["int32"]},{"Name":"IgnoreWords","Docs":"","Typewords":["float64"]},{"Name":"RareWords","Docs":"","Typewords":["int32"]}]},
+	"JunkReevaluation": {"Name":"JunkReevaluation","Docs":"","Fields":[{"Name":"Window","Docs":"","Typewords":["int64"]},{"Name":"Sender","Docs":"","Typewords":["bool"]},{"Name":"DNSBL","Docs":"","Typewords":["bool"]},{"Name":"Spamtrap","Docs":"","Typewords":["bool"]},{"Name":"Notify","Docs":"","Typewords":["bool"]}]},
+	"Screening": {"Name":"Screening","Docs":"","Fields":[{"Name":"Mailbox","Docs":"","Typewords":["string"]}]},
+	"Categories": {"Name":"Categories","Docs":"","Fields":[{"Name":"Newsletters","Docs":"","Typewords":["nullable","Category"]},{"Name":"Notifications","Docs":"","Typewords":["nullable","Category"]},{"Name":"Social","Docs":"","Typewords":["nullable","Category"]}]},
+	"Category": {"Name":"Category","Docs":"","Fields":[{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Domains","Docs":"","Typewords":["[]","string"]}]},
+	"PasswordPolicy": {"Name":"PasswordPolicy","Docs":"","Fields":[{"Name":"MinLength","Docs":"","Typewords":["int32"]},{"Name":"MinEntropy","Docs":"","Typewords":["int32"]},{"Name":"MaxAge","Docs":"","Typewords":["int64"]},{"Name":"LockoutFailures","Docs":"","Typewords":["int32"]},{"Name":"LockoutPeriod","Docs":"","Typewords":["int64"]}]},
+	"Identity": {"Name":"Identity","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"DisplayName","Docs":"","Typewords":["string"]},{"Name":"Signature","Docs":"","Typewords":["[]","string"]},{"Name":"SignatureHTML","Docs":"","Typewords":["string"]},{"Name":"ReplyQuoting","Docs":"","Typewords":["string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Default","Docs":"","Typewords":["bool"]}]},
+	"Delegation": {"Name":"Delegation","Docs":"","Fields":[{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailboxes","Docs":"","Typewords":["[]","string"]},{"Name":"Write","Docs":"","Typewords":["bool"]},{"Name":"Send","Docs":"","Typewords":["string"]}]},
+	"AddressAlias": {"Name":"AddressAlias","Docs":"","Fields":[{"Name":"SubscriptionAddress","Docs":"","Typewords":["string"]},{"Name":"Alias","Docs":"","Typewords":["Alias"]},{"Name":"MemberAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"Member","Docs":"","Typewords":["AliasMember"]}]},
 	"PolicyRecord": {"Name":"PolicyRecord","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Inserted","Docs":"","Typewords":["timestamp"]},{"Name":"ValidEnd","Docs":"","Typewords":["timestamp"]},{"Name":"LastUpdate","Docs":"","Typewords":["timestamp"]},{"Name":"LastUse","Docs":"","Typewords":["timestamp"]},{"Name":"Backoff","Docs":"","Typewords":["bool"]},{"Name":"RecordID","Docs":"","Typewords":["string"]},{"Name":"Version","Docs":"","Typewords":["string"]},{"Name":"Mode","Docs":"","Typewords":["Mode"]},{"Name":"MX","Docs":"","Typewords":["[]","STSMX"]},{"Name":"MaxAgeSeconds","Docs":"","Typewords":["int32"]},{"Name":"Extensions","Docs":"","Typewords":["[]","Pair"]},{"Name":"PolicyText","Docs":"","Typewords":["string"]}]},
 	"TLSReportRecord": {"Name":"TLSReportRecord","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"FromDomain","Docs":"","Typewords":["string"]},{"Name":"MailFrom","Docs":"","Typewords":["string"]},{"Name":"HostReport","Docs":"","Typewords":["bool"]},{"Name":"Report","Docs":"","Typewords":["Report"]}]},
 	"Report": {"Name":"Report","Docs":"","Fields":[{"Name":"OrganizationName","Docs":"","Typewords":["string"]},{"Name":"DateRange","Docs":"","Typewords":["TLSRPTDateRange"]},{"Name":"ContactInfo","Docs":"","Typewords":["string"]},{"Name":"ReportID","Docs":"","Typewords":["string"]},{"Name":"Policies","Docs":"","Typewords":["[]","Result"]}]},
@@ -1215,6 +2221,12 @@ export const types: TypenameMap = {
 	"Summary": {"Name":"Summary","Docs":"","Fields":[{"Name":"TotalSuccessfulSessionCount","Docs":"","Typewords":["int64"]},{"Name":"TotalFailureSessionCount","Docs":"","Typewords":["int64"]}]},
 	"FailureDetails": {"Name":"FailureDetails","Docs":"","Fields":[{"Name":"ResultType","Docs":"","Typewords":["string"]},{"Name":"SendingMTAIP","Docs":"","Typewords":["string"]},{"Name":"ReceivingMXHostname","Docs":"","Typewords":["string"]},{"Name":"ReceivingMXHelo","Docs":"","Typewords":["string"]},{"Name":"ReceivingIP","Docs":"","Typewords":["string"]},{"Name":"FailedSessionCount","Docs":"","Typewords":["int64"]},{"Name":"AdditionalInformation","Docs":"","Typewords":["string"]},{"Name":"FailureReasonCode","Docs":"","Typewords":["string"]}]},
 	"TLSRPTSummary": {"Name":"TLSRPTSummary","Docs":"","Fields":[{"Name":"PolicyDomain","Docs":"","Typewords":["Domain"]},{"Name":"Success","Docs":"","Typewords":["int64"]},{"Name":"Failure","Docs":"","Typewords":["int64"]},{"Name":"ResultTypeCounts","Docs":"","Typewords":["{}","int64"]}]},
+	"Stats": {"Name":"Stats","Docs":"","Fields":[{"Name":"PolicyDomain","Docs":"","Typewords":["string"]},{"Name":"Reports","Docs":"","Typewords":["int32"]},{"Name":"Success","Docs":"","Typewords":["int64"]},{"Name":"Failure","Docs":"","Typewords":["int64"]},{"Name":"Categories","Docs":"","Typewords":["[]","StatsCategory"]},{"Name":"ResultTypes","Docs":"","Typewords":["[]","StatsResultType"]},{"Name":"Days","Docs":"","Typewords":["[]","StatsDay"]},{"Name":"Reporters","Docs":"","Typewords":["[]","StatsReporter"]}]},
+	"StatsCategory": {"Name":"StatsCategory","Docs":"","Fields":[{"Name":"Category","Docs":"","Typewords":["FailureCategory"]},{"Name":"Failure","Docs":"","Typewords":["int64"]}]},
+	"StatsResultType": {"Name":"StatsResultType","Docs":"","Fields":[{"Name":"ResultType","Docs":"","Typewords":["string"]},{"Name":"Category","Docs":"","Typewords":["FailureCategory"]},{"Name":"Failure","Docs":"","Typewords":["int64"]},{"Name":"Reports","Docs":"","Typewords":["int32"]},{"Name":"MXHosts","Docs":"","Typewords":["[]","string"]},{"Name":"Reasons","Docs":"","Typewords":["[]","string"]}]},
+	"StatsDay": {"Name":"StatsDay","Docs":"","Fields":[{"Name":"Day","Docs":"","Typewords":["string"]},{"Name":"Success","Docs":"","Typewords":["int64"]},{"Name":"Failure","Docs":"","Typewords":["int64"]}]},
+	"StatsReporter": {"Name":"StatsReporter","Docs":"","Fields":[{"Name":"OrganizationName","Docs":"","Typewords":["string"]},{"Name":"Reports","Docs":"","Typewords":["int32"]},{"Name":"Success","Docs":"","Typewords":["int64"]},{"Name":"Failure","Docs":"","Typewords":["int64"]}]},
+	"FailureType": {"Name":"FailureType","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"PolicyDomain","Docs":"","Typewords":["string"]},{"Name":"ResultType","Docs":"","Typewords":["string"]},{"Name":"First","Docs":"","Typewords":["timestamp"]},{"Name":"Last","Docs":"","Typewords":["timestamp"]},{"Name":"Sessions","Docs":"","Typewords":["int64"]}]},
 	"DomainFeedback": {"Name":"DomainFeedback","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"FromDomain","Docs":"","Typewords":["string"]},{"Name":"Version","Docs":"","Typewords":["string"]},{"Name":"ReportMetadata","Docs":"","Typewords":["ReportMetadata"]},{"Name":"PolicyPublished","Docs":"","Typewords":["PolicyPublished"]},{"Name":"Records","Docs":"","Typewords":["[]","ReportRecord"]}]},
 	"ReportMetadata": {"Name":"ReportMetadata","Docs":"","Fields":[{"Name":"OrgName","Docs":"","Typewords":["string"]},{"Name":"Email","Docs":"","Typewords":["string"]},{"Name":"ExtraContactInfo","Docs":"","Typewords":["string"]},{"Name":"ReportID","Docs":"","Typewords":["string"]},{"Name":"DateRange","Docs":"","Typewords":["DateRange"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]}]},
 	"DateRange": {"Name":"DateRange","Docs":"","Fields":[{"Name":"Begin","Docs":"","Typewords":["int64"]},{"Name":"End","Docs":"","Typewords":["int64"]}]},
@@ -1227,19 +2239,56 @@ export const types: TypenameMap = {
 	"AuthResults": {"Name":"AuthResults","Docs":"","Fields":[{"Name":"DKIM","Docs":"","Typewords":["[]","DKIMAuthResult"]},{"Name":"SPF","Docs":"","Typewords":["[]","SPFAuthResult"]}]},
 	"DKIMAuthResult": {"Name":"DKIMAuthResult","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Selector","Docs":"","Typewords":["string"]},{"Name":"Result","Docs":"","Typewords":["string"]},{"Name":"HumanResult","Docs":"","Typewords":["string"]}]},
 	"SPFAuthResult": {"Name":"SPFAuthResult","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Scope","Docs":"","Typewords":["string"]},{"Name":"Result","Docs":"","Typewords":["string"]}]},
+	"DomainFailureReport": {"Name":"DomainFailureReport","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Received","Docs":"","Typewords":["timestamp"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"FromDomain","Docs":"","Typewords":["string"]},{"Name":"FeedbackType","Docs":"","Typewords":["string"]},{"Name":"UserAgent","Docs":"","Typewords":["string"]},{"Name":"Version","Docs":"","Typewords":["string"]},{"Name":"OriginalMailFrom","Docs":"","Typewords":["string"]},{"Name":"OriginalRcptTo","Docs":"","Typewords":["[]","string"]},{"Name":"ArrivalDate","Docs":"","Typewords":["string"]},{"Name":"SourceIP","Docs":"","Typewords":["string"]},{"Name":"ReportedDomain","Docs":"","Typewords":["string"]},{"Name":"AuthenticationResults","Docs":"","Typewords":["[]","string"]},{"Name":"AuthFailure","Docs":"","Typewords":["string"]},{"Name":"IdentityAlignment","Docs":"","Typewords":["string"]},{"Name":"DeliveryResult","Docs":"","Typewords":["string"]},{"Name":"DKIMDomain","Docs":"","Typewords":["string"]},{"Name":"DKIMIdentity","Docs":"","Typewords":["string"]},{"Name":"DKIMSelector","Docs":"","Typewords":["string"]},{"Name":"SPFDNS","Docs":"","Typewords":["string"]},{"Name":"Header","Docs":"","Typewords":["string"]}]},
 	"DMARCSummary": {"Name":"DMARCSummary","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Total","Docs":"","Typewords":["int32"]},{"Name":"DispositionNone","Docs":"","Typewords":["int32"]},{"Name":"DispositionQuarantine","Docs":"","Typewords":["int32"]},{"Name":"DispositionReject","Docs":"","Typewords":["int32"]},{"Name":"DKIMFail","Docs":"","Typewords":["int32"]},{"Name":"SPFFail","Docs":"","Typewords":["int32"]},{"Name":"PolicyOverrides","Docs":"","Typewords":["{}","int32"]}]},
+	"Analytics": {"Name":"Analytics","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Reports","Docs":"","Typewords":["int32"]},{"Name":"Total","Docs":"","Typewords":["int32"]},{"Name":"Pass","Docs":"","Typewords":["int32"]},{"Name":"Fail","Docs":"","Typewords":["int32"]},{"Name":"DKIMAlignedPass","Docs":"","Typewords":["int32"]},{"Name":"SPFAlignedPass","Docs":"","Typewords":["int32"]},{"Name":"DKIMAuthPass","Docs":"","Typewords":["int32"]},{"Name":"SPFAuthPass","Docs":"","Typewords":["int32"]},{"Name":"Quarantine","Docs":"","Typewords":["int32"]},{"Name":"Reject","Docs":"","Typewords":["int32"]},{"Name":"Days","Docs":"","Typewords":["[]","AnalyticsDay"]},{"Name":"Sources","Docs":"","Typewords":["[]","AnalyticsSource"]},{"Name":"FailingSources","Docs":"","Typewords":["[]","AnalyticsSource"]},{"Name":"Reporters","Docs":"","Typewords":["[]","AnalyticsReporter"]}]},
+	"AnalyticsDay": {"Name":"AnalyticsDay","Docs":"","Fields":[{"Name":"Day","Docs":"","Typewords":["string"]},{"Name":"Total","Docs":"","Typewords":["int32"]},{"Name":"Fail","Docs":"","Typewords":["int32"]},{"Name":"DKIMFail","Docs":"","Typewords":["int32"]},{"Name":"SPFFail","Docs":"","Typewords":["int32"]}]},
+	"AnalyticsSource": {"Name":"AnalyticsSource","Docs":"","Fields":[{"Name":"IP","Docs":"","Typewords":["string"]},{"Name":"Total","Docs":"","Typewords":["int32"]},{"Name":"Fail","Docs":"","Typewords":["int32"]},{"Name":"DKIMFail","Docs":"","Typewords":["int32"]},{"Name":"SPFFail","Docs":"","Typewords":["int32"]},{"Name":"Domains","Docs":"","Typewords":["[]","string"]}]},
+	"AnalyticsReporter": {"Name":"AnalyticsReporter","Docs":"","Fields":[{"Name":"OrgName","Docs":"","Typewords":["string"]},{"Name":"Reports","Docs":"","Typewords":["int32"]},{"Name":"Total","Docs":"","Typewords":["int32"]},{"Name":"Fail","Docs":"","Typewords":["int32"]}]},
+	"PostmasterData": {"Name":"PostmasterData","Docs":"","Fields":[{"Name":"SNDS","Docs":"","Typewords":["[]","SNDSRecord"]},{"Name":"Google","Docs":"","Typewords":["[]","GoogleStats"]},{"Name":"Volumes","Docs":"","Typewords":["[]","Volume"]}]},
+	"SNDSRecord": {"Name":"SNDSRecord","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"IP","Docs":"","Typewords":["string"]},{"Name":"ActivityStart","Docs":"","Typewords":["timestamp"]},{"Name":"ActivityEnd","Docs":"","Typewords":["timestamp"]},{"Name":"RcptCommands","Docs":"","Typewords":["int32"]},{"Name":"DataCommands","Docs":"","Typewords":["int32"]},{"Name":"MessageRecipients","Docs":"","Typewords":["int32"]},{"Name":"FilterResult","Docs":"","Typewords":["string"]},{"Name":"ComplaintRate","Docs":"","Typewords":["string"]},{"Name":"TrapPeriodStart","Docs":"","Typewords":["timestamp"]},{"Name":"TrapPeriodEnd","Docs":"","Typewords":["timestamp"]},{"Name":"TrapHits","Docs":"","Typewords":["int32"]},{"Name":"SampleHELO","Docs":"","Typewords":["string"]},{"Name":"SampleMailFrom","Docs":"","Typewords":["string"]},{"Name":"Comments","Docs":"","Typewords":["string"]},{"Name":"Updated","Docs":"","Typewords":["timestamp"]}]},
+	"GoogleStats": {"Name":"GoogleStats","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"DayUTC","Docs":"","Typewords":["string"]},{"Name":"UserReportedSpamRatio","Docs":"","Typewords":["float64"]},{"Name":"DomainReputation","Docs":"","Typewords":["string"]},{"Name":"IPReputations","Docs":"","Typewords":["[]","GoogleIPReputation"]},{"Name":"SPFSuccessRatio","Docs":"","Typewords":["float64"]},{"Name":"DKIMSuccessRatio","Docs":"","Typewords":["float64"]},{"Name":"DMARCSuccessRatio","Docs":"","Typewords":["float64"]},{"Name":"OutboundEncryptionRatio","Docs":"","Typewords":["float64"]},{"Name":"DeliveryErrors","Docs":"","Typewords":["[]","GoogleDeliveryError"]},{"Name":"Updated","Docs":"","Typewords":["timestamp"]}]},
+	"GoogleIPReputation": {"Name":"GoogleIPReputation","Docs":"","Fields":[{"Name":"Reputation","Docs":"","Typewords":["string"]},{"Name":"IPCount","Docs":"","Typewords":["int64"]},{"Name":"SampleIPs","Docs":"","Typewords":["[]","string"]}]},
+	"GoogleDeliveryError": {"Name":"GoogleDeliveryError","Docs":"","Fields":[{"Name":"ErrorClass","Docs":"","Typewords":["string"]},{"Name":"ErrorType","Docs":"","Typewords":["string"]},{"Name":"ErrorRatio","Docs":"","Typewords":["float64"]}]},
+	"Volume": {"Name":"Volume","Docs":"","Fields":[{"Name":"DayUTC","Docs":"","Typewords":["string"]},{"Name":"Provider","Docs":"","Typewords":["string"]},{"Name":"SenderDomain","Docs":"","Typewords":["string"]},{"Name":"Delivered","Docs":"","Typewords":["int32"]},{"Name":"Failed","Docs":"","Typewords":["int32"]}]},
+	"Sender": {"Name":"Sender","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Type","Docs":"","Typewords":["SenderType"]},{"Name":"Value","Docs":"","Typewords":["string"]},{"Name":"First","Docs":"","Typewords":["timestamp"]},{"Name":"Last","Docs":"","Typewords":["timestamp"]},{"Name":"Accepted","Docs":"","Typewords":["int64"]},{"Name":"Rejected","Docs":"","Typewords":["int64"]},{"Name":"UnknownRecipients","Docs":"","Typewords":["int64"]},{"Name":"SPFPass","Docs":"","Typewords":["int64"]},{"Name":"DKIMPass","Docs":"","Typewords":["int64"]},{"Name":"DMARCPass","Docs":"","Typewords":["int64"]},{"Name":"DMARCFail","Docs":"","Typewords":["int64"]},{"Name":"Junk","Docs":"","Typewords":["int64"]},{"Name":"Notjunk","Docs":"","Typewords":["int64"]},{"Name":"Spamtraps","Docs":"","Typewords":["int64"]},{"Name":"Override","Docs":"","Typewords":["Override"]},{"Name":"OverrideUpdated","Docs":"","Typewords":["timestamp"]}]},
 	"Reverse": {"Name":"Reverse","Docs":"","Fields":[{"Name":"Hostnames","Docs":"","Typewords":["[]","string"]}]},
+	"Analysis": {"Name":"Analysis","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"Record","Docs":"","Typewords":["nullable","AnalysisRecord"]},{"Name":"DNSLookups","Docs":"","Typewords":["int32"]},{"Name":"VoidLookups","Docs":"","Typewords":["int32"]},{"Name":"Authentic","Docs":"","Typewords":["bool"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]}]},
+	"AnalysisRecord": {"Name":"AnalysisRecord","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"TXT","Docs":"","Typewords":["string"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"Mechanisms","Docs":"","Typewords":["[]","AnalysisMechanism"]}]},
+	"AnalysisMechanism": {"Name":"AnalysisMechanism","Docs":"","Fields":[{"Name":"Mechanism","Docs":"","Typewords":["string"]},{"Name":"Expanded","Docs":"","Typewords":["string"]},{"Name":"DNSLookups","Docs":"","Typewords":["int32"]},{"Name":"Void","Docs":"","Typewords":["bool"]},{"Name":"IPs","Docs":"","Typewords":["[]","string"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"Include","Docs":"","Typewords":["nullable","AnalysisRecord"]}]},
+	"DANEHost": {"Name":"DANEHost","Docs":"","Fields":[{"Name":"Host","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Authentic","Docs":"","Typewords":["bool"]},{"Name":"Keys","Docs":"","Typewords":["[]","DANEKey"]},{"Name":"Published","Docs":"","Typewords":["[]","string"]},{"Name":"Records","Docs":"","Typewords":["[]","string"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]}]},
+	"DANEKey": {"Name":"DANEKey","Docs":"","Fields":[{"Name":"KeyType","Docs":"","Typewords":["string"]},{"Name":"TLSA","Docs":"","Typewords":["string"]},{"Name":"Current","Docs":"","Typewords":["bool"]},{"Name":"Expires","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Next","Docs":"","Typewords":["bool"]},{"Name":"Configured","Docs":"","Typewords":["bool"]},{"Name":"Published","Docs":"","Typewords":["bool"]}]},
+	"AccountImportResult": {"Name":"AccountImportResult","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"Password","Docs":"","Typewords":["string"]},{"Name":"Error","Docs":"","Typewords":["string"]}]},
+	"SendSuspension": {"Name":"SendSuspension","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["uint8"]},{"Name":"Suspended","Docs":"","Typewords":["timestamp"]},{"Name":"Reason","Docs":"","Typewords":["string"]}]},
+	"MessageStorage": {"Name":"MessageStorage","Docs":"","Fields":[{"Name":"Messages","Docs":"","Typewords":["int32"]},{"Name":"Compressed","Docs":"","Typewords":["int32"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"StoredSize","Docs":"","Typewords":["int64"]}]},
+	"JunkFilterStats": {"Name":"JunkFilterStats","Docs":"","Fields":[{"Name":"Account","Docs":"","Typewords":["JunkStats"]},{"Name":"Shared","Docs":"","Typewords":["nullable","JunkStats"]}]},
+	"JunkStats": {"Name":"JunkStats","Docs":"","Fields":[{"Name":"Hams","Docs":"","Typewords":["uint32"]},{"Name":"Spams","Docs":"","Typewords":["uint32"]},{"Name":"Words","Docs":"","Typewords":["int32"]}]},
+	"JunkVerdict": {"Name":"JunkVerdict","Docs":"","Fields":[{"Name":"MessageID","Docs":"","Typewords":["int64"]},{"Name":"Received","Docs":"","Typewords":["timestamp"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Junk","Docs":"","Typewords":["bool"]},{"Name":"Notjunk","Docs":"","Typewords":["bool"]},{"Name":"Verdict","Docs":"","Typewords":["MessageJunkVerdict"]}]},
+	"MessageJunkVerdict": {"Name":"MessageJunkVerdict","Docs":"","Fields":[{"Name":"Accept","Docs":"","Typewords":["bool"]},{"Name":"Reason","Docs":"","Typewords":["string"]},{"Name":"Details","Docs":"","Typewords":["[]","string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Classified","Docs":"","Typewords":["bool"]},{"Name":"Score","Docs":"","Typewords":["float64"]},{"Name":"Threshold","Docs":"","Typewords":["float64"]},{"Name":"Significant","Docs":"","Typewords":["bool"]},{"Name":"HamWords","Docs":"","Typewords":["[]","MessageJunkWord"]},{"Name":"SpamWords","Docs":"","Typewords":["[]","MessageJunkWord"]}]},
+	"MessageJunkWord": {"Name":"MessageJunkWord","Docs":"","Fields":[{"Name":"Word","Docs":"","Typewords":["string"]},{"Name":"Score","Docs":"","Typewords":["float64"]}]},
+	"Passkey": {"Name":"Passkey","Docs":"","Fields":[{"Name":"CredentialID","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]},{"Name":"SignCount","Docs":"","Typewords":["uint32"]},{"Name":"LastUsed","Docs":"","Typewords":["timestamp"]}]},
+	"TOTPSetup": {"Name":"TOTPSetup","Docs":"","Fields":[{"Name":"Secret","Docs":"","Typewords":["string"]},{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"QRCodePNG","Docs":"","Typewords":["string"]}]},
+	"PasskeyCreateOptions": {"Name":"PasskeyCreateOptions","Docs":"","Fields":[{"Name":"Challenge","Docs":"","Typewords":["nullable","string"]},{"Name":"RPID","Docs":"","Typewords":["string"]},{"Name":"RPName","Docs":"","Typewords":["string"]},{"Name":"UserID","Docs":"","Typewords":["nullable","string"]},{"Name":"UserName","Docs":"","Typewords":["string"]},{"Name":"Algorithms","Docs":"","Typewords":["[]","int32"]},{"Name":"ExcludeCredentials","Docs":"","Typewords":["[]","nullable","string"]},{"Name":"Timeout","Docs":"","Typewords":["int32"]}]},
+	"AdminUser": {"Name":"AdminUser","Docs":"","Fields":[{"Name":"Role","Docs":"","Typewords":["string"]},{"Name":"Domains","Docs":"","Typewords":["[]","string"]}]},
+	"SignupRequest": {"Name":"SignupRequest","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"State","Docs":"","Typewords":["SignupState"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"FullName","Docs":"","Typewords":["string"]},{"Name":"ContactEmail","Docs":"","Typewords":["string"]},{"Name":"InviteID","Docs":"","Typewords":["int64"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"Verified","Docs":"","Typewords":["timestamp"]},{"Name":"Decided","Docs":"","Typewords":["timestamp"]},{"Name":"DecidedBy","Docs":"","Typewords":["string"]},{"Name":"RejectReason","Docs":"","Typewords":["string"]}]},
+	"SignupInvite": {"Name":"SignupInvite","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Expires","Docs":"","Typewords":["timestamp"]},{"Name":"MaxUses","Docs":"","Typewords":["int32"]},{"Name":"Uses","Docs":"","Typewords":["int32"]},{"Name":"Note","Docs":"","Typewords":["string"]},{"Name":"CreatedBy","Docs":"","Typewords":["string"]}]},
 	"ClientConfigs": {"Name":"ClientConfigs","Docs":"","Fields":[{"Name":"Entries","Docs":"","Typewords":["[]","ClientConfigsEntry"]}]},
 	"ClientConfigsEntry": {"Name":"ClientConfigsEntry","Docs":"","Fields":[{"Name":"Protocol","Docs":"","Typewords":["string"]},{"Name":"Host","Docs":"","Typewords":["Domain"]},{"Name":"Port","Docs":"","Typewords":["int32"]},{"Name":"Listener","Docs":"","Typewords":["string"]},{"Name":"Note","Docs":"","Typewords":["string"]}]},
 	"HoldRule": {"Name":"HoldRule","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"SenderDomain","Docs":"","Typewords":["Domain"]},{"Name":"RecipientDomain","Docs":"","Typewords":["Domain"]},{"Name":"SenderDomainStr","Docs":"","Typewords":["string"]},{"Name":"RecipientDomainStr","Docs":"","Typewords":["string"]}]},
-	"Filter": {"Name":"Filter","Docs":"","Fields":[{"Name":"Max","Docs":"","Typewords":["int32"]},{"Name":"IDs","Docs":"","Typewords":["[]","int64"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["string"]},{"Name":"Hold","Docs":"","Typewords":["nullable","bool"]},{"Name":"Submitted","Docs":"","Typewords":["string"]},{"Name":"NextAttempt","Docs":"","Typewords":["string"]},{"Name":"Transport","Docs":"","Typewords":["nullable","string"]}]},
+	"Filter": {"Name":"Filter","Docs":"","Fields":[{"Name":"Max","Docs":"","Typewords":["int32"]},{"Name":"IDs","Docs":"","Typewords":["[]","int64"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["string"]},{"Name":"Hold","Docs":"","Typewords":["nullable","bool"]},{"Name":"Submitted","Docs":"","Typewords":["string"]},{"Name":"NextAttempt","Docs":"","Typewords":["string"]},{"Name":"Transport","Docs":"","Typewords":["nullable","string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"LastError","Docs":"","Typewords":["string"]}]},
 	"Sort": {"Name":"Sort","Docs":"","Fields":[{"Name":"Field","Docs":"","Typewords":["string"]},{"Name":"LastID","Docs":"","Typewords":["int64"]},{"Name":"Last","Docs":"","Typewords":["any"]},{"Name":"Asc","Docs":"","Typewords":["bool"]}]},
-	"Msg": {"Name":"Msg","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"BaseID","Docs":"","Typewords":["int64"]},{"Name":"Queued","Docs":"","Typewords":["timestamp"]},{"Name":"Hold","Docs":"","Typewords":["bool"]},{"Name":"SenderAccount","Docs":"","Typewords":["string"]},{"Name":"SenderLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"SenderDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"SenderDomainStr","Docs":"","Typewords":["string"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"RecipientLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RecipientDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"RecipientDomainStr","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"DialedIPs","Docs":"","Typewords":["{}","[]","IP"]},{"Name":"NextAttempt","Docs":"","Typewords":["timestamp"]},{"Name":"LastAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Results","Docs":"","Typewords":["[]","MsgResult"]},{"Name":"Has8bit","Docs":"","Typewords":["bool"]},{"Name":"SMTPUTF8","Docs":"","Typewords":["bool"]},{"Name":"IsDMARCReport","Docs":"","Typewords":["bool"]},{"Name":"IsTLSReport","Docs":"","Typewords":["bool"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"MsgPrefix","Docs":"","Typewords":["nullable","string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"DSNUTF8","Docs":"","Typewords":["nullable","string"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureReleaseRequest","Docs":"","Typewords":["string"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]}]},
+	"Msg": {"Name":"Msg","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"BaseID","Docs":"","Typewords":["int64"]},{"Name":"Queued","Docs":"","Typewords":["timestamp"]},{"Name":"Hold","Docs":"","Typewords":["bool"]},{"Name":"HoldManual","Docs":"","Typewords":["bool"]},{"Name":"SenderAccount","Docs":"","Typewords":["string"]},{"Name":"SenderLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"SenderDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"SenderDomainStr","Docs":"","Typewords":["string"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"RecipientLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RecipientDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"RecipientDomainStr","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"DialedIPs","Docs":"","Typewords":["{}","[]","IP"]},{"Name":"NextAttempt","Docs":"","Typewords":["timestamp"]},{"Name":"LastAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Results","Docs":"","Typewords":["[]","MsgResult"]},{"Name":"Has8bit","Docs":"","Typewords":["bool"]},{"Name":"SMTPUTF8","Docs":"","Typewords":["bool"]},{"Name":"IsDMARCReport","Docs":"","Typewords":["bool"]},{"Name":"IsTLSReport","Docs":"","Typewords":["bool"]},{"Name":"IsListMessage","Docs":"","Typewords":["bool"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"MsgPrefix","Docs":"","Typewords":["nullable","string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"DSNUTF8","Docs":"","Typewords":["nullable","string"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureReleaseRequest","Docs":"","Typewords":["string"]},{"Name":"Priority","Docs":"","Typewords":["int32"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]},{"Name":"Changes","Docs":"","Typewords":["[]","MsgChange"]}]},
 	"IPDomain": {"Name":"IPDomain","Docs":"","Fields":[{"Name":"IP","Docs":"","Typewords":["IP"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]}]},
-	"MsgResult": {"Name":"MsgResult","Docs":"","Fields":[{"Name":"Start","Docs":"","Typewords":["timestamp"]},{"Name":"Duration","Docs":"","Typewords":["int64"]},{"Name":"Success","Docs":"","Typewords":["bool"]},{"Name":"Code","Docs":"","Typewords":["int32"]},{"Name":"Secode","Docs":"","Typewords":["string"]},{"Name":"Error","Docs":"","Typewords":["string"]}]},
+	"MsgResult": {"Name":"MsgResult","Docs":"","Fields":[{"Name":"Start","Docs":"","Typewords":["timestamp"]},{"Name":"Duration","Docs":"","Typewords":["int64"]},{"Name":"Success","Docs":"","Typewords":["bool"]},{"Name":"Code","Docs":"","Typewords":["int32"]},{"Name":"Secode","Docs":"","Typewords":["string"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"RemoteMTA","Docs":"","Typewords":["string"]},{"Name":"Response","Docs":"","Typewords":["[]","string"]},{"Name":"TLS","Docs":"","Typewords":["string"]},{"Name":"MTASTS","Docs":"","Typewords":["bool"]},{"Name":"DANE","Docs":"","Typewords":["bool"]},{"Name":"Transcript","Docs":"","Typewords":["string"]},{"Name":"DNSLookups","Docs":"","Typewords":["[]","Lookup"]},{"Name":"DANEStatus","Docs":"","Typewords":["string"]}]},
+	"Lookup": {"Name":"Lookup","Docs":"","Fields":[{"Name":"Type","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Authentic","Docs":"","Typewords":["bool"]},{"Name":"Error","Docs":"","Typewords":["string"]}]},
+	"MsgChange": {"Name":"MsgChange","Docs":"","Fields":[{"Name":"Time","Docs":"","Typewords":["timestamp"]},{"Name":"Description","Docs":"","Typewords":["string"]}]},
+	"MsgRewrite": {"Name":"MsgRewrite","Docs":"","Fields":[{"Name":"Recipient","Docs":"","Typewords":["nullable","string"]},{"Name":"Transport","Docs":"","Typewords":["nullable","string"]},{"Name":"From","Docs":"","Typewords":["nullable","string"]},{"Name":"Subject","Docs":"","Typewords":["nullable","string"]}]},
 	"RetiredFilter": {"Name":"RetiredFilter","Docs":"","Fields":[{"Name":"Max","Docs":"","Typewords":["int32"]},{"Name":"IDs","Docs":"","Typewords":["[]","int64"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["string"]},{"Name":"Submitted","Docs":"","Typewords":["string"]},{"Name":"LastActivity","Docs":"","Typewords":["string"]},{"Name":"Transport","Docs":"","Typewords":["nullable","string"]},{"Name":"Success","Docs":"","Typewords":["nullable","bool"]}]},
 	"RetiredSort": {"Name":"RetiredSort","Docs":"","Fields":[{"Name":"Field","Docs":"","Typewords":["string"]},{"Name":"LastID","Docs":"","Typewords":["int64"]},{"Name":"Last","Docs":"","Typewords":["any"]},{"Name":"Asc","Docs":"","Typewords":["bool"]}]},
-	"MsgRetired": {"Name":"MsgRetired","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"BaseID","Docs":"","Typewords":["int64"]},{"Name":"Queued","Docs":"","Typewords":["timestamp"]},{"Name":"SenderAccount","Docs":"","Typewords":["string"]},{"Name":"SenderLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"SenderDomainStr","Docs":"","Typewords":["string"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"RecipientLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RecipientDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"RecipientDomainStr","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"DialedIPs","Docs":"","Typewords":["{}","[]","IP"]},{"Name":"LastAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Results","Docs":"","Typewords":["[]","MsgResult"]},{"Name":"Has8bit","Docs":"","Typewords":["bool"]},{"Name":"SMTPUTF8","Docs":"","Typewords":["bool"]},{"Name":"IsDMARCReport","Docs":"","Typewords":["bool"]},{"Name":"IsTLSReport","Docs":"","Typewords":["bool"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureReleaseRequest","Docs":"","Typewords":["string"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]},{"Name":"LastActivity","Docs":"","Typewords":["timestamp"]},{"Name":"RecipientAddress","Docs":"","Typewords":["string"]},{"Name":"Success","Docs":"","Typewords":["bool"]},{"Name":"KeepUntil","Docs":"","Typewords":["timestamp"]}]},
+	"MsgRetired": {"Name":"MsgRetired","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"BaseID","Docs":"","Typewords":["int64"]},{"Name":"Queued","Docs":"","Typewords":["timestamp"]},{"Name":"SenderAccount","Docs":"","Typewords":["string"]},{"Name":"SenderLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"SenderDomainStr","Docs":"","Typewords":["string"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"RecipientLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RecipientDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"RecipientDomainStr","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"DialedIPs","Docs":"","Typewords":["{}","[]","IP"]},{"Name":"LastAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Results","Docs":"","Typewords":["[]","MsgResult"]},{"Name":"Has8bit","Docs":"","Typewords":["bool"]},{"Name":"SMTPUTF8","Docs":"","Typewords":["bool"]},{"Name":"IsDMARCReport","Docs":"","Typewords":["bool"]},{"Name":"IsTLSReport","Docs":"","Typewords":["bool"]},{"Name":"IsListMessage","Docs":"","Typewords":["bool"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureReleaseRequest","Docs":"","Typewords":["string"]},{"Name":"Priority","Docs":"","Typewords":["int32"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]},{"Name":"Changes","Docs":"","Typewords":["[]","MsgChange"]},{"Name":"LastActivity","Docs":"","Typewords":["timestamp"]},{"Name":"RecipientAddress","Docs":"","Typewords":["string"]},{"Name":"Success","Docs":"","Typewords":["bool"]},{"Name":"KeepUntil","Docs":"","Typewords":["timestamp"]}]},
+	"DeadFilter": {"Name":"DeadFilter","Docs":"","Fields":[{"Name":"Max","Docs":"","Typewords":["int32"]},{"Name":"IDs","Docs":"","Typewords":["[]","int64"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["string"]}]},
+	"MsgDead": {"Name":"MsgDead","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Queued","Docs":"","Typewords":["timestamp"]},{"Name":"SenderAccount","Docs":"","Typewords":["string"]},{"Name":"SenderLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"SenderDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"SenderDomainStr","Docs":"","Typewords":["string"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"RecipientLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RecipientDomain","Docs":"","Typewords":["IPDomain"]},{"Name":"RecipientDomainStr","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"DialedIPs","Docs":"","Typewords":["{}","[]","IP"]},{"Name":"LastAttempt","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Results","Docs":"","Typewords":["[]","MsgResult"]},{"Name":"Has8bit","Docs":"","Typewords":["bool"]},{"Name":"SMTPUTF8","Docs":"","Typewords":["bool"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"MsgPrefix","Docs":"","Typewords":["nullable","string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Transport","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureReleaseRequest","Docs":"","Typewords":["string"]},{"Name":"Priority","Docs":"","Typewords":["int32"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]},{"Name":"Failed","Docs":"","Typewords":["timestamp"]},{"Name":"KeepUntil","Docs":"","Typewords":["timestamp"]}]},
+	"HostHealth": {"Name":"HostHealth","Docs":"","Fields":[{"Name":"Host","Docs":"","Typewords":["string"]},{"Name":"LastAttempt","Docs":"","Typewords":["timestamp"]},{"Name":"LastSuccess","Docs":"","Typewords":["timestamp"]},{"Name":"LastFailure","Docs":"","Typewords":["timestamp"]},{"Name":"LastError","Docs":"","Typewords":["string"]},{"Name":"Attempts","Docs":"","Typewords":["int64"]},{"Name":"Successes","Docs":"","Typewords":["int64"]},{"Name":"ConnectFailures","Docs":"","Typewords":["int64"]},{"Name":"TLSFailures","Docs":"","Typewords":["int64"]},{"Name":"TemporaryFailures","Docs":"","Typewords":["int64"]},{"Name":"ConsecutiveFailures","Docs":"","Typewords":["int32"]},{"Name":"CooldownUntil","Docs":"","Typewords":["timestamp"]}]},
 	"HookFilter": {"Name":"HookFilter","Docs":"","Fields":[{"Name":"Max","Docs":"","Typewords":["int32"]},{"Name":"IDs","Docs":"","Typewords":["[]","int64"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Submitted","Docs":"","Typewords":["string"]},{"Name":"NextAttempt","Docs":"","Typewords":["string"]},{"Name":"Event","Docs":"","Typewords":["string"]}]},
 	"HookSort": {"Name":"HookSort","Docs":"","Fields":[{"Name":"Field","Docs":"","Typewords":["string"]},{"Name":"LastID","Docs":"","Typewords":["int64"]},{"Name":"Last","Docs":"","Typewords":["any"]},{"Name":"Asc","Docs":"","Typewords":["bool"]}]},
 	"Hook": {"Name":"Hook","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"QueueMsgID","Docs":"","Typewords":["int64"]},{"Name":"FromID","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Extra","Docs":"","Typewords":["{}","string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Authorization","Docs":"","Typewords":["string"]},{"Name":"IsIncoming","Docs":"","Typewords":["bool"]},{"Name":"OutgoingEvent","Docs":"","Typewords":["string"]},{"Name":"Payload","Docs":"","Typewords":["string"]},{"Name":"Submitted","Docs":"","Typewords":["timestamp"]},{"Name":"Attempts","Docs":"","Typewords":["int32"]},{"Name":"NextAttempt","Docs":"","Typewords":["timestamp"]},{"Name":"Results","Docs":"","Typewords":["[]","HookResult"]}]},
@@ -1257,26 +2306,47 @@ export const types: TypenameMap = {
 	"TransportSMTP": {"Name":"TransportSMTP","Docs":"","Fields":[{"Name":"Host","Docs":"","Typewords":["string"]},{"Name":"Port","Docs":"","Typewords":["int32"]},{"Name":"STARTTLSInsecureSkipVerify","Docs":"","Typewords":["bool"]},{"Name":"NoSTARTTLS","Docs":"","Typewords":["bool"]},{"Name":"Auth","Docs":"","Typewords":["nullable","SMTPAuth"]}]},
 	"SMTPAuth": {"Name":"SMTPAuth","Docs":"","Fields":[{"Name":"Username","Docs":"","Typewords":["string"]},{"Name":"Password","Docs":"","Typewords":["string"]},{"Name":"Mechanisms","Docs":"","Typewords":["[]","string"]}]},
 	"TransportSocks": {"Name":"TransportSocks","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"RemoteIPs","Docs":"","Typewords":["[]","string"]},{"Name":"RemoteHostname","Docs":"","Typewords":["string"]}]},
-	"TransportDirect": {"Name":"TransportDirect","Docs":"","Fields":[{"Name":"DisableIPv4","Docs":"","Typewords":["bool"]},{"Name":"DisableIPv6","Docs":"","Typewords":["bool"]}]},
+	"TransportDirect": {"Name":"TransportDirect","Docs":"","Fields":[{"Name":"DisableIPv4","Docs":"","Typewords":["bool"]},{"Name":"DisableIPv6","Docs":"","Typewords":["bool"]},{"Name":"PreferIPFamily","Docs":"","Typewords":["string"]},{"Name":"HappyEyeballsDelay","Docs":"","Typewords":["int64"]},{"Name":"DisableHappyEyeballs","Docs":"","Typewords":["bool"]}]},
 	"EvaluationStat": {"Name":"EvaluationStat","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["Domain"]},{"Name":"Dispositions","Docs":"","Typewords":["[]","string"]},{"Name":"Count","Docs":"","Typewords":["int32"]},{"Name":"SendReport","Docs":"","Typewords":["bool"]}]},
 	"Evaluation": {"Name":"Evaluation","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"PolicyDomain","Docs":"","Typewords":["string"]},{"Name":"Evaluated","Docs":"","Typewords":["timestamp"]},{"Name":"Optional","Docs":"","Typewords":["bool"]},{"Name":"IntervalHours","Docs":"","Typewords":["int32"]},{"Name":"Addresses","Docs":"","Typewords":["[]","string"]},{"Name":"PolicyPublished","Docs":"","Typewords":["PolicyPublished"]},{"Name":"SourceIP","Docs":"","Typewords":["string"]},{"Name":"Disposition","Docs":"","Typewords":["string"]},{"Name":"AlignedDKIMPass","Docs":"","Typewords":["bool"]},{"Name":"AlignedSPFPass","Docs":"","Typewords":["bool"]},{"Name":"OverrideReasons","Docs":"","Typewords":["[]","PolicyOverrideReason"]},{"Name":"EnvelopeTo","Docs":"","Typewords":["string"]},{"Name":"EnvelopeFrom","Docs":"","Typewords":["string"]},{"Name":"HeaderFrom","Docs":"","Typewords":["string"]},{"Name":"DKIMResults","Docs":"","Typewords":["[]","DKIMAuthResult"]},{"Name":"SPFResults","Docs":"","Typewords":["[]","SPFAuthResult"]}]},
 	"SuppressAddress": {"Name":"SuppressAddress","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Inserted","Docs":"","Typewords":["timestamp"]},{"Name":"ReportingAddress","Docs":"","Typewords":["string"]},{"Name":"Until","Docs":"","Typewords":["timestamp"]},{"Name":"Comment","Docs":"","Typewords":["string"]}]},
 	"TLSResult": {"Name":"TLSResult","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"PolicyDomain","Docs":"","Typewords":["string"]},{"Name":"DayUTC","Docs":"","Typewords":["string"]},{"Name":"RecipientDomain","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Updated","Docs":"","Typewords":["timestamp"]},{"Name":"IsHost","Docs":"","Typewords":["bool"]},{"Name":"SendReport","Docs":"","Typewords":["bool"]},{"Name":"SentToRecipientDomain","Docs":"","Typewords":["bool"]},{"Name":"RecipientDomainReportingAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"SentToPolicyDomain","Docs":"","Typewords":["bool"]},{"Name":"Results","Docs":"","Typewords":["[]","Result"]}]},
 	"TLSRPTSuppressAddress": {"Name":"TLSRPTSuppressAddress","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Inserted","Docs":"","Typewords":["timestamp"]},{"Name":"ReportingAddress","Docs":"","Typewords":["string"]},{"Name":"Until","Docs":"","Typewords":["timestamp"]},{"Name":"Comment","Docs":"","Typewords":["string"]}]},
-	"Dynamic": {"Name":"Dynamic","Docs":"","Fields":[{"Name":"Domains","Docs":"","Typewords":["{}","ConfigDomain"]},{"Name":"Accounts","Docs":"","Typewords":["{}","Account"]},{"Name":"WebDomainRedirects","Docs":"","Typewords":["{}","string"]},{"Name":"WebHandlers","Docs":"","Typewords":["[]","WebHandler"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"MonitorDNSBLs","Docs":"","Typewords":["[]","string"]},{"Name":"MonitorDNSBLZones","Docs":"","Typewords":["[]","Domain"]}]},
+	"Dynamic": {"Name":"Dynamic","Docs":"","Fields":[{"Name":"Domains","Docs":"","Typewords":["{}","ConfigDomain"]},{"Name":"Accounts","Docs":"","Typewords":["{}","Account"]},{"Name":"WebDomainRedirects","Docs":"","Typewords":["{}","string"]},{"Name":"WebHandlers","Docs":"","Typewords":["[]","WebHandler"]},{"Name":"Routes","Docs":"","Typewords":["[]","Route"]},{"Name":"RetrySchedules","Docs":"","Typewords":["[]","RetrySchedule"]},{"Name":"TLSPolicies","Docs":"","Typewords":["[]","TLSPolicy"]},{"Name":"MonitorDNSBLs","Docs":"","Typewords":["[]","string"]},{"Name":"MonitorDNSBLZones","Docs":"","Typewords":["[]","Domain"]}]},
+	"RetrySchedule": {"Name":"RetrySchedule","Docs":"","Fields":[{"Name":"ToDomain","Docs":"","Typewords":["[]","string"]},{"Name":"Class","Docs":"","Typewords":["string"]},{"Name":"MinPriority","Docs":"","Typewords":["int32"]},{"Name":"MaxPriority","Docs":"","Typewords":["int32"]},{"Name":"Intervals","Docs":"","Typewords":["[]","int64"]},{"Name":"MaxAttempts","Docs":"","Typewords":["int32"]},{"Name":"MaxAge","Docs":"","Typewords":["int64"]},{"Name":"ToDomainASCII","Docs":"","Typewords":["[]","string"]}]},
+	"TLSPolicy": {"Name":"TLSPolicy","Docs":"","Fields":[{"Name":"ToDomain","Docs":"","Typewords":["[]","string"]},{"Name":"Mode","Docs":"","Typewords":["string"]},{"Name":"CertificateSHA256","Docs":"","Typewords":["[]","string"]},{"Name":"ToDomainASCII","Docs":"","Typewords":["[]","string"]}]},
+	"DynamicConfig": {"Name":"DynamicConfig","Docs":"","Fields":[{"Name":"Config","Docs":"","Typewords":["Dynamic"]},{"Name":"Text","Docs":"","Typewords":["string"]},{"Name":"Hash","Docs":"","Typewords":["string"]}]},
+	"DKIMRotationStatus": {"Name":"DKIMRotationStatus","Docs":"","Fields":[{"Name":"Config","Docs":"","Typewords":["nullable","DKIMRotation"]},{"Name":"Provisioned","Docs":"","Typewords":["bool"]},{"Name":"Rotated","Docs":"","Typewords":["timestamp"]},{"Name":"Added","Docs":"","Typewords":["timestamp"]},{"Name":"Switched","Docs":"","Typewords":["timestamp"]},{"Name":"Old","Docs":"","Typewords":["[]","string"]},{"Name":"New","Docs":"","Typewords":["[]","string"]},{"Name":"NextStage","Docs":"","Typewords":["string"]},{"Name":"Next","Docs":"","Typewords":["timestamp"]},{"Name":"Tasks","Docs":"","Typewords":["[]","DKIMRotationTask"]},{"Name":"History","Docs":"","Typewords":["[]","DKIMRotationEvent"]}]},
+	"DKIMRotationTask": {"Name":"DKIMRotationTask","Docs":"","Fields":[{"Name":"Action","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Value","Docs":"","Typewords":["string"]}]},
+	"DKIMRotationEvent": {"Name":"DKIMRotationEvent","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Time","Docs":"","Typewords":["timestamp"]},{"Name":"Kind","Docs":"","Typewords":["string"]},{"Name":"Selectors","Docs":"","Typewords":["[]","string"]},{"Name":"Text","Docs":"","Typewords":["string"]}]},
+	"Subscriber": {"Name":"Subscriber","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"List","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"Token","Docs":"","Typewords":["string"]},{"Name":"Confirmed","Docs":"","Typewords":["bool"]},{"Name":"Digest","Docs":"","Typewords":["bool"]},{"Name":"Bounces","Docs":"","Typewords":["int32"]},{"Name":"LastBounce","Docs":"","Typewords":["timestamp"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]}]},
+	"Message": {"Name":"Message","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"List","Docs":"","Typewords":["string"]},{"Name":"Received","Docs":"","Typewords":["timestamp"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"Archive","Docs":"","Typewords":["bool"]},{"Name":"DigestPending","Docs":"","Typewords":["bool"]}]},
 	"TLSPublicKey": {"Name":"TLSPublicKey","Docs":"","Fields":[{"Name":"Fingerprint","Docs":"","Typewords":["string"]},{"Name":"Created","Docs":"","Typewords":["timestamp"]},{"Name":"Type","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"NoIMAPPreauth","Docs":"","Typewords":["bool"]},{"Name":"CertDER","Docs":"","Typewords":["nullable","string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]}]},
 	"LoginAttempt": {"Name":"LoginAttempt","Docs":"","Fields":[{"Name":"Key","Docs":"","Typewords":["nullable","string"]},{"Name":"Last","Docs":"","Typewords":["timestamp"]},{"Name":"First","Docs":"","Typewords":["timestamp"]},{"Name":"Count","Docs":"","Typewords":["int64"]},{"Name":"AccountName","Docs":"","Typewords":["string"]},{"Name":"LoginAddress","Docs":"","Typewords":["string"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"LocalIP","Docs":"","Typewords":["string"]},{"Name":"TLS","Docs":"","Typewords":["string"]},{"Name":"TLSPubKeyFingerprint","Docs":"","Typewords":["string"]},{"Name":"Protocol","Docs":"","Typewords":["string"]},{"Name":"UserAgent","Docs":"","Typewords":["string"]},{"Name":"AuthMech","Docs":"","Typewords":["string"]},{"Name":"Result","Docs":"","Typewords":["AuthResult"]}]},
+	"AuditFilter": {"Name":"AuditFilter","Docs":"","Fields":[{"Name":"Kind","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Actor","Docs":"","Typewords":["string"]},{"Name":"Start","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"End","Docs":"","Typewords":["nullable","timestamp"]}]},
+	"AuditEvent": {"Name":"AuditEvent","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Time","Docs":"","Typewords":["timestamp"]},{"Name":"Kind","Docs":"","Typewords":["string"]},{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Actor","Docs":"","Typewords":["string"]},{"Name":"Protocol","Docs":"","Typewords":["string"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"Action","Docs":"","Typewords":["string"]},{"Name":"Result","Docs":"","Typewords":["string"]},{"Name":"Details","Docs":"","Typewords":["string"]}]},
+	"QuotaStatus": {"Name":"QuotaStatus","Docs":"","Fields":[{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"MessageSize","Docs":"","Typewords":["int64"]},{"Name":"Quota","Docs":"","Typewords":["int64"]},{"Name":"SoftLimit","Docs":"","Typewords":["int64"]},{"Name":"Percent","Docs":"","Typewords":["int32"]},{"Name":"GraceEnd","Docs":"","Typewords":["timestamp"]}]},
+	"MTASTSRollout": {"Name":"MTASTSRollout","Docs":"","Fields":[{"Name":"Policy","Docs":"","Typewords":["nullable","MTASTS"]},{"Name":"NextMode","Docs":"","Typewords":["string"]},{"Name":"Ready","Docs":"","Typewords":["bool"]},{"Name":"Reasons","Docs":"","Typewords":["[]","string"]},{"Name":"TestingDays","Docs":"","Typewords":["int32"]},{"Name":"TestingSuccess","Docs":"","Typewords":["int64"]},{"Name":"TestingFailure","Docs":"","Typewords":["int64"]},{"Name":"RecentFailure","Docs":"","Typewords":["int64"]},{"Name":"FailureTypes","Docs":"","Typewords":["{}","int64"]},{"Name":"LastReportPeriod","Docs":"","Typewords":["timestamp"]}]},
+	"DomainReadiness": {"Name":"DomainReadiness","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Checked","Docs":"","Typewords":["timestamp"]},{"Name":"Checking","Docs":"","Typewords":["bool"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"Ready","Docs":"","Typewords":["bool"]},{"Name":"Done","Docs":"","Typewords":["int32"]},{"Name":"Items","Docs":"","Typewords":["[]","ReadinessItem"]},{"Name":"Records","Docs":"","Typewords":["[]","string"]}]},
+	"ReadinessItem": {"Name":"ReadinessItem","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Status","Docs":"","Typewords":["string"]},{"Name":"Errors","Docs":"","Typewords":["[]","string"]},{"Name":"Warnings","Docs":"","Typewords":["[]","string"]},{"Name":"Instructions","Docs":"","Typewords":["[]","string"]}]},
 	"CSRFToken": {"Name":"CSRFToken","Docs":"","Values":null},
 	"DMARCPolicy": {"Name":"DMARCPolicy","Docs":"","Values":[{"Name":"PolicyEmpty","Value":"","Docs":""},{"Name":"PolicyNone","Value":"none","Docs":""},{"Name":"PolicyQuarantine","Value":"quarantine","Docs":""},{"Name":"PolicyReject","Value":"reject","Docs":""}]},
 	"Align": {"Name":"Align","Docs":"","Values":[{"Name":"AlignStrict","Value":"s","Docs":""},{"Name":"AlignRelaxed","Value":"r","Docs":""}]},
 	"RUA": {"Name":"RUA","Docs":"","Values":null},
 	"Mode": {"Name":"Mode","Docs":"","Values":[{"Name":"ModeEnforce","Value":"enforce","Docs":""},{"Name":"ModeTesting","Value":"testing","Docs":""},{"Name":"ModeNone","Value":"none","Docs":""}]},
 	"Localpart": {"Name":"Localpart","Docs":"","Values":null},
+	"FailureCategory": {"Name":"FailureCategory","Docs":"","Values":[{"Name":"CategorySTARTTLS","Value":"starttls","Docs":""},{"Name":"CategoryCertificate","Value":"certificate","Docs":""},{"Name":"CategoryDANE","Value":"dane","Docs":""},{"Name":"CategoryMTASTS","Value":"mtasts","Docs":""},{"Name":"CategoryOther","Value":"other","Docs":""}]},
+	"SenderType": {"Name":"SenderType","Docs":"","Values":[{"Name":"SenderDomain","Value":"domain","Docs":""},{"Name":"SenderIP","Value":"ip","Docs":""}]},
+	"Override": {"Name":"Override","Docs":"","Values":[{"Name":"OverrideNone","Value":"","Docs":""},{"Name":"OverrideAllow","Value":"allow","Docs":""},{"Name":"OverrideQuarantine","Value":"quarantine","Docs":""}]},
+	"Status": {"Name":"Status","Docs":"","Values":[{"Name":"StatusNone","Value":"none","Docs":""},{"Name":"StatusNeutral","Value":"neutral","Docs":""},{"Name":"StatusPass","Value":"pass","Docs":""},{"Name":"StatusFail","Value":"fail","Docs":""},{"Name":"StatusSoftfail","Value":"softfail","Docs":""},{"Name":"StatusTemperror","Value":"temperror","Docs":""},{"Name":"StatusPermerror","Value":"permerror","Docs":""}]},
+	"SignupState": {"Name":"SignupState","Docs":"","Values":[{"Name":"SignupUnverified","Value":"unverified","Docs":""},{"Name":"SignupPending","Value":"pending","Docs":""},{"Name":"SignupApproved","Value":"approved","Docs":""},{"Name":"SignupRejected","Value":"rejected","Docs":""}]},
 	"IP": {"Name":"IP","Docs":"","Values":[]},
-	"AuthResult": {"Name":"AuthResult","Docs":"","Values":[{"Name":"AuthSuccess","Value":"ok","Docs":""},{"Name":"AuthBadUser","Value":"baduser","Docs":""},{"Name":"AuthBadPassword","Value":"badpassword","Docs":""},{"Name":"AuthBadCredentials","Value":"badcreds","Docs":""},{"Name":"AuthBadChannelBinding","Value":"badchanbind","Docs":""},{"Name":"AuthBadProtocol","Value":"badprotocol","Docs":""},{"Name":"AuthLoginDisabled","Value":"logindisabled","Docs":""},{"Name":"AuthError","Value":"error","Docs":""},{"Name":"AuthAborted","Value":"aborted","Docs":""}]},
+	"AuthResult": {"Name":"AuthResult","Docs":"","Values":[{"Name":"AuthSuccess","Value":"ok","Docs":""},{"Name":"AuthBadUser","Value":"baduser","Docs":""},{"Name":"AuthBadPassword","Value":"badpassword","Docs":""},{"Name":"AuthBadCredentials","Value":"badcreds","Docs":""},{"Name":"AuthBadChannelBinding","Value":"badchanbind","Docs":""},{"Name":"AuthBadProtocol","Value":"badprotocol","Docs":""},{"Name":"AuthLoginDisabled","Value":"logindisabled","Docs":""},{"Name":"AuthLoginLocked","Value":"loginlocked","Docs":""},{"Name":"AuthPasswordExpired","Value":"passwordexpired","Docs":""},{"Name":"AuthAppPasswordRequired","Value":"apppasswordrequired","Docs":""},{"Name":"AuthTOTPRequired","Value":"totprequired","Docs":""},{"Name":"AuthTwoFactorSetup","Value":"twofactorsetup","Docs":""},{"Name":"AuthError","Value":"error","Docs":""},{"Name":"AuthAborted","Value":"aborted","Docs":""}]},
 }
 
 export const parser = {
+	PasskeyGetOptions: (v: any) => parse("PasskeyGetOptions", v) as PasskeyGetOptions,
+	PasskeyAssertion: (v: any) => parse("PasskeyAssertion", v) as PasskeyAssertion,
 	CheckResult: (v: any) => parse("CheckResult", v) as CheckResult,
 	DNSSECResult: (v: any) => parse("DNSSECResult", v) as DNSSECResult,
 	IPRevCheckResult: (v: any) => parse("IPRevCheckResult", v) as IPRevCheckResult,
@@ -1312,21 +2382,42 @@ export const parser = {
 	DKIM: (v: any) => parse("DKIM", v) as DKIM,
 	Selector: (v: any) => parse("Selector", v) as Selector,
 	Canonicalization: (v: any) => parse("Canonicalization", v) as Canonicalization,
+	DKIMSignRule: (v: any) => parse("DKIMSignRule", v) as DKIMSignRule,
+	DKIMRotation: (v: any) => parse("DKIMRotation", v) as DKIMRotation,
 	DMARC: (v: any) => parse("DMARC", v) as DMARC,
 	MTASTS: (v: any) => parse("MTASTS", v) as MTASTS,
 	TLSRPT: (v: any) => parse("TLSRPT", v) as TLSRPT,
 	Route: (v: any) => parse("Route", v) as Route,
 	Alias: (v: any) => parse("Alias", v) as Alias,
+	AliasMember: (v: any) => parse("AliasMember", v) as AliasMember,
+	AliasList: (v: any) => parse("AliasList", v) as AliasList,
 	AliasAddress: (v: any) => parse("AliasAddress", v) as AliasAddress,
 	Address: (v: any) => parse("Address", v) as Address,
 	Destination: (v: any) => parse("Destination", v) as Destination,
 	Ruleset: (v: any) => parse("Ruleset", v) as Ruleset,
+	OutgoingHeaderRules: (v: any) => parse("OutgoingHeaderRules", v) as OutgoingHeaderRules,
+	OutgoingFooter: (v: any) => parse("OutgoingFooter", v) as OutgoingFooter,
+	MessageTemplate: (v: any) => parse("MessageTemplate", v) as MessageTemplate,
+	DNSProvisioning: (v: any) => parse("DNSProvisioning", v) as DNSProvisioning,
+	DomainSignup: (v: any) => parse("DomainSignup", v) as DomainSignup,
+	DomainWeb: (v: any) => parse("DomainWeb", v) as DomainWeb,
+	WebmailDefaults: (v: any) => parse("WebmailDefaults", v) as WebmailDefaults,
+	BIMI: (v: any) => parse("BIMI", v) as BIMI,
+	SpamActions: (v: any) => parse("SpamActions", v) as SpamActions,
+	SharedJunkFilter: (v: any) => parse("SharedJunkFilter", v) as SharedJunkFilter,
 	Account: (v: any) => parse("Account", v) as Account,
 	OutgoingWebhook: (v: any) => parse("OutgoingWebhook", v) as OutgoingWebhook,
 	IncomingWebhook: (v: any) => parse("IncomingWebhook", v) as IncomingWebhook,
 	SubjectPass: (v: any) => parse("SubjectPass", v) as SubjectPass,
 	AutomaticJunkFlags: (v: any) => parse("AutomaticJunkFlags", v) as AutomaticJunkFlags,
 	JunkFilter: (v: any) => parse("JunkFilter", v) as JunkFilter,
+	JunkReevaluation: (v: any) => parse("JunkReevaluation", v) as JunkReevaluation,
+	Screening: (v: any) => parse("Screening", v) as Screening,
+	Categories: (v: any) => parse("Categories", v) as Categories,
+	Category: (v: any) => parse("Category", v) as Category,
+	PasswordPolicy: (v: any) => parse("PasswordPolicy", v) as PasswordPolicy,
+	Identity: (v: any) => parse("Identity", v) as Identity,
+	Delegation: (v: any) => parse("Delegation", v) as Delegation,
 	AddressAlias: (v: any) => parse("AddressAlias", v) as AddressAlias,
 	PolicyRecord: (v: any) => parse("PolicyRecord", v) as PolicyRecord,
 	TLSReportRecord: (v: any) => parse("TLSReportRecord", v) as TLSReportRecord,
@@ -1337,6 +2428,12 @@ export const parser = {
 	Summary: (v: any) => parse("Summary", v) as Summary,
 	FailureDetails: (v: any) => parse("FailureDetails", v) as FailureDetails,
 	TLSRPTSummary: (v: any) => parse("TLSRPTSummary", v) as TLSRPTSummary,
+	Stats: (v: any) => parse("Stats", v) as Stats,
+	StatsCategory: (v: any) => parse("StatsCategory", v) as StatsCategory,
+	StatsResultType: (v: any) => parse("StatsResultType", v) as StatsResultType,
+	StatsDay: (v: any) => parse("StatsDay", v) as StatsDay,
+	StatsReporter: (v: any) => parse("StatsReporter", v) as StatsReporter,
+	FailureType: (v: any) => parse("FailureType", v) as FailureType,
 	DomainFeedback: (v: any) => parse("DomainFeedback", v) as DomainFeedback,
 	ReportMetadata: (v: any) => parse("ReportMetadata", v) as ReportMetadata,
 	DateRange: (v: any) => parse("DateRange", v) as DateRange,
@@ -1349,8 +2446,39 @@ export const parser = {
 	AuthResults: (v: any) => parse("AuthResults", v) as AuthResults,
 	DKIMAuthResult: (v: any) => parse("DKIMAuthResult", v) as DKIMAuthResult,
 	SPFAuthResult: (v: any) => parse("SPFAuthResult", v) as SPFAuthResult,
+	DomainFailureReport: (v: any) => parse("DomainFailureReport", v) as DomainFailureReport,
 	DMARCSummary: (v: any) => parse("DMARCSummary", v) as DMARCSummary,
+	Analytics: (v: any) => parse("Analytics", v) as Analytics,
+	AnalyticsDay: (v: any) => parse("AnalyticsDay", v) as AnalyticsDay,
+	AnalyticsSource: (v: any) => parse("AnalyticsSource", v) as AnalyticsSource,
+	AnalyticsReporter: (v: any) => parse("AnalyticsReporter", v) as AnalyticsReporter,
+	PostmasterData: (v: any) => parse("PostmasterData", v) as PostmasterData,
+	SNDSRecord: (v: any) => parse("SNDSRecord", v) as SNDSRecord,
+	GoogleStats: (v: any) => parse("GoogleStats", v) as GoogleStats,
+	GoogleIPReputation: (v: any) => parse("GoogleIPReputation", v) as GoogleIPReputation,
+	GoogleDeliveryError: (v: any) => parse("GoogleDeliveryError", v) as GoogleDeliveryError,
+	Volume: (v: any) => parse("Volume", v) as Volume,
+	Sender: (v: any) => parse("Sender", v) as Sender,
 	Reverse: (v: any) => parse("Reverse", v) as Reverse,
+	Analysis: (v: any) => parse("Analysis", v) as Analysis,
+	AnalysisRecord: (v: any) => parse("AnalysisRecord", v) as AnalysisRecord,
+	AnalysisMechanism: (v: any) => parse("AnalysisMechanism", v) as AnalysisMechanism,
+	DANEHost: (v: any) => parse("DANEHost", v) as DANEHost,
+	DANEKey: (v: any) => parse("DANEKey", v) as DANEKey,
+	AccountImportResult: (v: any) => parse("AccountImportResult", v) as AccountImportResult,
+	SendSuspension: (v: any) => parse("SendSuspension", v) as SendSuspension,
+	MessageStorage: (v: any) => parse("MessageStorage", v) as MessageStorage,
+	JunkFilterStats: (v: any) => parse("JunkFilterStats", v) as JunkFilterStats,
+	JunkStats: (v: any) => parse("JunkStats", v) as JunkStats,
+	JunkVerdict: (v: any) => parse("JunkVerdict", v) as JunkVerdict,
+	MessageJunkVerdict: (v: any) => parse("MessageJunkVerdict", v) as MessageJunkVerdict,
+	MessageJunkWord: (v: any) => parse("MessageJunkWord", v) as MessageJunkWord,
+	Passkey: (v: any) => parse("Passkey", v) as Passkey,
+	TOTPSetup: (v: any) => parse("TOTPSetup", v) as TOTPSetup,
+	PasskeyCreateOptions: (v: any) => parse("PasskeyCreateOptions", v) as PasskeyCreateOptions,
+	AdminUser: (v: any) => parse("AdminUser", v) as AdminUser,
+	SignupRequest: (v: any) => parse("SignupRequest", v) as SignupRequest,
+	SignupInvite: (v: any) => parse("SignupInvite", v) as SignupInvite,
 	ClientConfigs: (v: any) => parse("ClientConfigs", v) as ClientConfigs,
 	ClientConfigsEntry: (v: any) => parse("ClientConfigsEntry", v) as ClientConfigsEntry,
 	HoldRule: (v: any) => parse("HoldRule", v) as HoldRule,
@@ -1359,9 +2487,15 @@ export const parser = {
 	Msg: (v: any) => parse("Msg", v) as Msg,
 	IPDomain: (v: any) => parse("IPDomain", v) as IPDomain,
 	MsgResult: (v: any) => parse("MsgResult", v) as MsgResult,
+	Lookup: (v: any) => parse("Lookup", v) as Lookup,
+	MsgChange: (v: any) => parse("MsgChange", v) as MsgChange,
+	MsgRewrite: (v: any) => parse("MsgRewrite", v) as MsgRewrite,
 	RetiredFilter: (v: any) => parse("RetiredFilter", v) as RetiredFilter,
 	RetiredSort: (v: any) => parse("RetiredSort", v) as RetiredSort,
 	MsgRetired: (v: any) => parse("MsgRetired", v) as MsgRetired,
+	DeadFilter: (v: any) => parse("DeadFilter", v) as DeadFilter,
+	MsgDead: (v: any) => parse("MsgDead", v) as MsgDead,
+	HostHealth: (v: any) => parse("HostHealth", v) as HostHealth,
 	HookFilter: (v: any) => parse("HookFilter", v) as HookFilter,
 	HookSort: (v: any) => parse("HookSort", v) as HookSort,
 	Hook: (v: any) => parse("Hook", v) as Hook,
@@ -1386,14 +2520,33 @@ export const parser = {
 	TLSResult: (v: any) => parse("TLSResult", v) as TLSResult,
 	TLSRPTSuppressAddress: (v: any) => parse("TLSRPTSuppressAddress", v) as TLSRPTSuppressAddress,
 	Dynamic: (v: any) => parse("Dynamic", v) as Dynamic,
+	RetrySchedule: (v: any) => parse("RetrySchedule", v) as RetrySchedule,
+	TLSPolicy: (v: any) => parse("TLSPolicy", v) as TLSPolicy,
+	DynamicConfig: (v: any) => parse("DynamicConfig", v) as DynamicConfig,
+	DKIMRotationStatus: (v: any) => parse("DKIMRotationStatus", v) as DKIMRotationStatus,
+	DKIMRotationTask: (v: any) => parse("DKIMRotationTask", v) as DKIMRotationTask,
+	DKIMRotationEvent: (v: any) => parse("DKIMRotationEvent", v) as DKIMRotationEvent,
+	Subscriber: (v: any) => parse("Subscriber", v) as Subscriber,
+	Message: (v: any) => parse("Message", v) as Message,
 	TLSPublicKey: (v: any) => parse("TLSPublicKey", v) as TLSPublicKey,
 	LoginAttempt: (v: any) => parse("LoginAttempt", v) as LoginAttempt,
+	AuditFilter: (v: any) => parse("AuditFilter", v) as AuditFilter,
+	AuditEvent: (v: any) => parse("AuditEvent", v) as AuditEvent,
+	QuotaStatus: (v: any) => parse("QuotaStatus", v) as QuotaStatus,
+	MTASTSRollout: (v: any) => parse("MTASTSRollout", v) as MTASTSRollout,
+	DomainReadiness: (v: any) => parse("DomainReadiness", v) as DomainReadiness,
+	ReadinessItem: (v: any) => parse("ReadinessItem", v) as ReadinessItem,
 	CSRFToken: (v: any) => parse("CSRFToken", v) as CSRFToken,
 	DMARCPolicy: (v: any) => parse("DMARCPolicy", v) as DMARCPolicy,
 	Align: (v: any) => parse("Align", v) as Align,
 	RUA: (v: any) => parse("RUA", v) as RUA,
 	Mode: (v: any) => parse("Mode", v) as Mode,
 	Localpart: (v: any) => parse("Localpart", v) as Localpart,
+	FailureCategory: (v: any) => parse("FailureCategory", v) as FailureCategory,
+	SenderType: (v: any) => parse("SenderType", v) as SenderType,
+	Override: (v: any) => parse("Override", v) as Override,
+	Status: (v: any) => parse("Status", v) as Status,
+	SignupState: (v: any) => parse("SignupState", v) as SignupState,
 	IP: (v: any) => parse("IP", v) as IP,
 	AuthResult: (v: any) => parse("AuthResult", v) as AuthResult,
 }
@@ -1439,12 +2592,39 @@ export class Client {
 	}
 
 	// Login returns a session token for the credentials, or fails with error code
-	// "user:badLogin". Call LoginPrep to get a loginToken.
-	async Login(loginToken: string, password: string): Promise<CSRFToken> {
+	// "user:badLogin". Call LoginPrep to get a loginToken. Without username, the
+	// admin password from mox.conf is used. Otherwise username is an admin user from
+	// domains.conf. If two-factor authentication is enabled for the admin password,
+	// totp must be a code from the authenticator app, otherwise the login fails with
+	// error code "user:totpRequired".
+	async Login(loginToken: string, username: string, password: string, totp: string): Promise<CSRFToken> {
 		const fn: string = "Login"
-		const paramTypes: string[][] = [["string"],["string"]]
+		const paramTypes: string[][] = [["string"],["string"],["string"],["string"]]
+		const returnTypes: string[][] = [["CSRFToken"]]
+		const params: any[] = [loginToken, username, password, totp]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CSRFToken
+	}
+
+	// PasskeyLoginStart returns the parameters for the browser to login with a
+	// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+	// of the browser.
+	async PasskeyLoginStart(loginToken: string): Promise<PasskeyGetOptions> {
+		const fn: string = "PasskeyLoginStart"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["PasskeyGetOptions"]]
+		const params: any[] = [loginToken]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PasskeyGetOptions
+	}
+
+	// PasskeyLogin returns a session token for a passkey login started with
+	// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+	// password, the passkey must verify the user, e.g. with a PIN. With the password,
+	// the passkey is the second factor.
+	async PasskeyLogin(loginToken: string, password: string, assertion: PasskeyAssertion): Promise<CSRFToken> {
+		const fn: string = "PasskeyLogin"
+		const paramTypes: string[][] = [["string"],["string"],["PasskeyAssertion"]]
 		const returnTypes: string[][] = [["CSRFToken"]]
-		const params: any[] = [loginToken, password]
+		const params: any[] = [loginToken, password, assertion]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CSRFToken
 	}
 
@@ -1476,7 +2656,8 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CheckResult
 	}
 
-	// Domains returns all configured domain names.
+	// Domains returns all configured domain names. For domain admins, only their
+	// domains are returned.
 	async Domains(): Promise<ConfigDomain[] | null> {
 		const fn: string = "Domains"
 		const paramTypes: string[][] = []
@@ -1521,7 +2702,9 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [{ [key: string]: string }, { [key: string]: Alias }]
 	}
 
-	// Accounts returns the names of all configured and all disabled accounts.
+	// Accounts returns the names of all configured and all disabled accounts. For
+	// domain admins, only accounts with one of their domains as default domain are
+	// returned.
 	async Accounts(): Promise<[string[] | null, string[] | null]> {
 		const fn: string = "Accounts"
 		const paramTypes: string[][] = []
@@ -1588,6 +2771,27 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as TLSRPTSummary[] | null
 	}
 
+	// TLSRPTStats returns statistics about failure types and trends for TLS reports
+	// overlapping with period start/end, for one or all policy domains (when domain is
+	// empty).
+	async TLSRPTStats(start: Date, end: Date, policyDomain: string): Promise<Stats> {
+		const fn: string = "TLSRPTStats"
+		const paramTypes: string[][] = [["timestamp"],["timestamp"],["string"]]
+		const returnTypes: string[][] = [["Stats"]]
+		const params: any[] = [start, end, policyDomain]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Stats
+	}
+
+	// TLSRPTFailureTypes returns the failure types seen in TLS reports for one or
+	// all policy domains (when domain is empty), most recently seen first.
+	async TLSRPTFailureTypes(policyDomain: string): Promise<FailureType[] | null> {
+		const fn: string = "TLSRPTFailureTypes"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["[]","FailureType"]]
+		const params: any[] = [policyDomain]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as FailureType[] | null
+	}
+
 	// DMARCReports returns DMARC reports overlapping with period start/end, for the
 	// given domain (or all domains if empty). The reports are sorted first by period
 	// end (most recent first), then by domain.
@@ -1608,6 +2812,25 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DomainFeedback
 	}
 
+	// DMARCFailureReports returns DMARC failure reports received during period
+	// start/end, for the given domain (or all domains if empty), most recent first.
+	async DMARCFailureReports(start: Date, end: Date, domain: string): Promise<DomainFailureReport[] | null> {
+		const fn: string = "DMARCFailureReports"
+		const paramTypes: string[][] = [["timestamp"],["timestamp"],["string"]]
+		const returnTypes: string[][] = [["[]","DomainFailureReport"]]
+		const params: any[] = [start, end, domain]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DomainFailureReport[] | null
+	}
+
+	// DMARCFailureReportID returns a single DMARC failure report.
+	async DMARCFailureReportID(domain: string, reportID: number): Promise<DomainFailureReport> {
+		const fn: string = "DMARCFailureReportID"
+		const paramTypes: string[][] = [["string"],["int64"]]
+		const returnTypes: string[][] = [["DomainFailureReport"]]
+		const params: any[] = [domain, reportID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DomainFailureReport
+	}
+
 	// DMARCSummaries returns a summary of received DMARC reports overlapping with
 	// period start/end for one or all domains (when domain is empty).
 	// The returned summaries are ordered by domain name.
@@ -1619,7 +2842,52 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DMARCSummary[] | null
 	}
 
-	// LookupIP does a reverse lookup of ip.
+	// DMARCAnalytics returns statistics and trends for DMARC reports overlapping
+	// with period start/end, for the given domain (or all domains if empty). At most
+	// 20 sources, failing sources and reporters are returned.
+	async DMARCAnalytics(start: Date, end: Date, domain: string): Promise<Analytics> {
+		const fn: string = "DMARCAnalytics"
+		const paramTypes: string[][] = [["timestamp"],["timestamp"],["string"]]
+		const returnTypes: string[][] = [["Analytics"]]
+		const params: any[] = [start, end, domain]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Analytics
+	}
+
+	// Postmaster returns reputation data from Microsoft SNDS and Google Postmaster
+	// Tools for the period start/end, along with our outgoing delivery volume to
+	// those providers during the period.
+	async Postmaster(start: Date, end: Date): Promise<PostmasterData> {
+		const fn: string = "Postmaster"
+		const paramTypes: string[][] = [["timestamp"],["timestamp"]]
+		const returnTypes: string[][] = [["PostmasterData"]]
+		const params: any[] = [start, end]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PostmasterData
+	}
+
+	// SenderReputations returns the server-wide reputation of sending domains and
+	// IPs of incoming deliveries, most recently seen first. Typ can be "domain", "ip"
+	// or empty for both. If overrides is set, only senders with an override are
+	// returned. At most 1000 senders are returned.
+	async SenderReputations(typ: string, overrides: boolean): Promise<Sender[] | null> {
+		const fn: string = "SenderReputations"
+		const paramTypes: string[][] = [["string"],["bool"]]
+		const returnTypes: string[][] = [["[]","Sender"]]
+		const params: any[] = [typ, overrides]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Sender[] | null
+	}
+
+	// SenderReputationOverride sets an override for the server-wide reputation of a
+	// sending domain or IP, or clears it if override is empty. A domain is changed to
+	// its organizational domain, an IPv6 address to its /64 network.
+	async SenderReputationOverride(typ: SenderType, value: string, override: Override): Promise<void> {
+		const fn: string = "SenderReputationOverride"
+		const paramTypes: string[][] = [["SenderType"],["string"],["Override"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [typ, value, override]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// LookupIP does a reverse lookup of ip.
 	async LookupIP(ip: string): Promise<Reverse> {
 		const fn: string = "LookupIP"
 		const paramTypes: string[][] = [["string"]]
@@ -1628,6 +2896,32 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Reverse
 	}
 
+	// SPFAnalyze looks up the SPF record of a domain and expands it fully, following
+	// includes and redirects. The analysis reports the number of DNS lookups against
+	// the limit of 10, void lookups, and problems such as overlapping IP networks.
+	// Useful for our own domains, and for debugging delivery to remote domains.
+	// 
+	// If ip is not empty, it is used for expanding macros, and is evaluated against
+	// the record, with the resulting status and matching mechanism returned.
+	async SPFAnalyze(domain: string, ip: string): Promise<[Analysis, Status, string, string]> {
+		const fn: string = "SPFAnalyze"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = [["Analysis"],["Status"],["string"],["string"]]
+		const params: any[] = [domain, ip]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [Analysis, Status, string, string]
+	}
+
+	// DANEHostStatus returns the DANE TLSA records for the keys of the current and
+	// next TLS certificates of the mail host, and checks them against the records in
+	// DNS, warning about key rollovers that would break DANE verification.
+	async DANEHostStatus(): Promise<DANEHost> {
+		const fn: string = "DANEHostStatus"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["DANEHost"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DANEHost
+	}
+
 	// DNSBLStatus returns the IPs from which outgoing connections may be made and
 	// their current status in DNSBLs that are configured. The IPs are typically the
 	// configured listen IPs, or otherwise IPs on the machines network interfaces, with
@@ -1689,6 +2983,18 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// AccountsImport adds multiple accounts from text in CSV or JSON format, see
+	// admin.ParseAccountImports. Either all accounts are added or none. The results
+	// have an error for each account that cannot be added, or the initial password
+	// for each added account. With dryRun, the accounts are only validated.
+	async AccountsImport(text: string, dryRun: boolean, requireChange: boolean): Promise<AccountImportResult[] | null> {
+		const fn: string = "AccountsImport"
+		const paramTypes: string[][] = [["string"],["bool"],["bool"]]
+		const returnTypes: string[][] = [["[]","AccountImportResult"]]
+		const params: any[] = [text, dryRun, requireChange]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as AccountImportResult[] | null
+	}
+
 	// AccountRemove removes an existing account and reloads the configuration.
 	async AccountRemove(accountName: string): Promise<void> {
 		const fn: string = "AccountRemove"
@@ -1727,6 +3033,286 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// PasswordRequireChange marks the password of an account as expired. A new
+	// password must be set through the account web interface before logging in with
+	// other protocols. Sessions of the account are removed.
+	async PasswordRequireChange(accountName: string): Promise<void> {
+		const fn: string = "PasswordRequireChange"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AccountSendSuspension returns the suspension of sending for an account after
+	// an anomaly in outgoing messages, or nil if sending is not suspended.
+	async AccountSendSuspension(accountName: string): Promise<SendSuspension | null> {
+		const fn: string = "AccountSendSuspension"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["nullable","SendSuspension"]]
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SendSuspension | null
+	}
+
+	// AccountSendResume resumes sending for an account that was suspended after an
+	// anomaly in outgoing messages.
+	async AccountSendResume(accountName: string): Promise<void> {
+		const fn: string = "AccountSendResume"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AccountStorage returns the number of messages in an account, and their total
+	// uncompressed and stored sizes, see MessageCompression.
+	async AccountStorage(accountName: string): Promise<MessageStorage> {
+		const fn: string = "AccountStorage"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["MessageStorage"]]
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MessageStorage
+	}
+
+	// AccountPasswordPolicySave sets the password policy for an account, with
+	// requirements for passwords, expiry and lockout after failed logins. A nil
+	// policy removes it.
+	async AccountPasswordPolicySave(accountName: string, policy: PasswordPolicy | null): Promise<void> {
+		const fn: string = "AccountPasswordPolicySave"
+		const paramTypes: string[][] = [["string"],["nullable","PasswordPolicy"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName, policy]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AccountTwoFactorEnabled returns whether the account has enabled two-factor
+	// authentication.
+	async AccountTwoFactorEnabled(accountName: string): Promise<boolean> {
+		const fn: string = "AccountTwoFactorEnabled"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["bool"]]
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as boolean
+	}
+
+	// AccountRequireTwoFactorSave sets whether the account must use two-factor
+	// authentication.
+	async AccountRequireTwoFactorSave(accountName: string, require0: boolean): Promise<void> {
+		const fn: string = "AccountRequireTwoFactorSave"
+		const paramTypes: string[][] = [["string"],["bool"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName, require0]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AccountTOTPRemove disables two-factor authentication for an account, e.g.
+	// after the account lost its authenticator app. If two-factor authentication is
+	// required, the account has to set it up again at the next login.
+	async AccountTOTPRemove(accountName: string): Promise<void> {
+		const fn: string = "AccountTOTPRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AccountJunkFilterStats returns the number of trained messages and known words
+	// of the junk filter of an account, and of the shared junk filter of its domain,
+	// if configured.
+	async AccountJunkFilterStats(accountName: string): Promise<JunkFilterStats> {
+		const fn: string = "AccountJunkFilterStats"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["JunkFilterStats"]]
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as JunkFilterStats
+	}
+
+	// AccountJunkVerdicts returns the most recently delivered messages of an account
+	// with the outcome of their junk analysis, for debugging misclassifications. If
+	// junkOnly is set, only messages that were rejected or are marked as junk are
+	// returned. If limit is greater than 0, at most limit messages are returned.
+	async AccountJunkVerdicts(accountName: string, junkOnly: boolean, limit: number): Promise<JunkVerdict[] | null> {
+		const fn: string = "AccountJunkVerdicts"
+		const paramTypes: string[][] = [["string"],["bool"],["int32"]]
+		const returnTypes: string[][] = [["[]","JunkVerdict"]]
+		const params: any[] = [accountName, junkOnly, limit]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as JunkVerdict[] | null
+	}
+
+	// AccountJunkFilterRetrain removes the junk filter of an account and trains a new
+	// junk filter from scratch with all messages marked as junk or not junk. The
+	// account is locked while retraining. Returns the number of messages in the
+	// account and the number of trained messages.
+	async AccountJunkFilterRetrain(accountName: string): Promise<[number, number]> {
+		const fn: string = "AccountJunkFilterRetrain"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["int32"],["int32"]]
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [number, number]
+	}
+
+	// AccountPasskeys returns the passkeys registered by an account.
+	async AccountPasskeys(accountName: string): Promise<Passkey[] | null> {
+		const fn: string = "AccountPasskeys"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["[]","Passkey"]]
+		const params: any[] = [accountName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Passkey[] | null
+	}
+
+	// AccountPasskeyRemove removes a passkey of an account, e.g. for a lost device.
+	async AccountPasskeyRemove(accountName: string, credentialID: string): Promise<void> {
+		const fn: string = "AccountPasskeyRemove"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName, credentialID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DomainRequireTwoFactorSave sets whether accounts with the domain as their
+	// default domain must use two-factor authentication.
+	async DomainRequireTwoFactorSave(domainName: string, require0: boolean): Promise<void> {
+		const fn: string = "DomainRequireTwoFactorSave"
+		const paramTypes: string[][] = [["string"],["bool"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName, require0]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminTOTPEnabled returns whether admin logins require a code from an
+	// authenticator app.
+	async AdminTOTPEnabled(): Promise<boolean> {
+		const fn: string = "AdminTOTPEnabled"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["bool"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as boolean
+	}
+
+	// AdminTOTPSetupStart starts enabling two-factor authentication for admin
+	// logins, returning the parameters for the authenticator app. Call
+	// AdminTOTPSetupConfirm with a code from the app to enable it.
+	async AdminTOTPSetupStart(): Promise<TOTPSetup> {
+		const fn: string = "AdminTOTPSetupStart"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["TOTPSetup"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as TOTPSetup
+	}
+
+	// AdminTOTPSetupConfirm enables two-factor authentication for admin logins
+	// after verifying a code from the authenticator app.
+	async AdminTOTPSetupConfirm(code: string): Promise<void> {
+		const fn: string = "AdminTOTPSetupConfirm"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [code]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminTOTPRemove disables two-factor authentication for admin logins.
+	async AdminTOTPRemove(): Promise<void> {
+		const fn: string = "AdminTOTPRemove"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = []
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminPasskeys returns the passkeys registered for admin logins.
+	async AdminPasskeys(): Promise<Passkey[] | null> {
+		const fn: string = "AdminPasskeys"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Passkey"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Passkey[] | null
+	}
+
+	// AdminPasskeyRegisterStart starts registration of a new passkey for admin
+	// logins, returning the parameters for the browser. Complete the registration
+	// with AdminPasskeyRegister.
+	async AdminPasskeyRegisterStart(): Promise<PasskeyCreateOptions> {
+		const fn: string = "AdminPasskeyRegisterStart"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["PasskeyCreateOptions"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PasskeyCreateOptions
+	}
+
+	// AdminPasskeyRegister completes registration of a passkey for admin logins with
+	// the response from the browser. Name describes the passkey, e.g. the device.
+	async AdminPasskeyRegister(name: string, clientDataJSON: string | null, attestationObject: string | null): Promise<Passkey> {
+		const fn: string = "AdminPasskeyRegister"
+		const paramTypes: string[][] = [["string"],["nullable","string"],["nullable","string"]]
+		const returnTypes: string[][] = [["Passkey"]]
+		const params: any[] = [name, clientDataJSON, attestationObject]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Passkey
+	}
+
+	// AdminPasskeyRemove removes a passkey for admin logins.
+	async AdminPasskeyRemove(credentialID: string): Promise<void> {
+		const fn: string = "AdminPasskeyRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [credentialID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminSelf returns the admin user of the session, with an empty name for logins
+	// with the admin password.
+	async AdminSelf(): Promise<[string, AdminUser]> {
+		const fn: string = "AdminSelf"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["string"],["AdminUser"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [string, AdminUser]
+	}
+
+	// AdminUsers returns the named admin users from domains.conf.
+	async AdminUsers(): Promise<{ [key: string]: AdminUser }> {
+		const fn: string = "AdminUsers"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["{}","AdminUser"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as { [key: string]: AdminUser }
+	}
+
+	// AdminUserAdd adds an admin user with a role, and domains for domain admins.
+	async AdminUserAdd(name: string, role: string, domains: string[] | null, password: string): Promise<void> {
+		const fn: string = "AdminUserAdd"
+		const paramTypes: string[][] = [["string"],["string"],["[]","string"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [name, role, domains, password]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminUserUpdate changes the role and domains of an admin user.
+	async AdminUserUpdate(name: string, role: string, domains: string[] | null): Promise<void> {
+		const fn: string = "AdminUserUpdate"
+		const paramTypes: string[][] = [["string"],["string"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [name, role, domains]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminUserSetPassword sets a new password for an admin user.
+	async AdminUserSetPassword(name: string, password: string): Promise<void> {
+		const fn: string = "AdminUserSetPassword"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [name, password]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AdminUserRemove removes an admin user.
+	async AdminUserRemove(name: string): Promise<void> {
+		const fn: string = "AdminUserRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [name]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// AccountSettingsSave set new settings for an account that only an admin can set.
 	async AccountSettingsSave(accountName: string, maxOutgoingMessagesPerDay: number, maxFirstTimeRecipientsPerDay: number, maxMsgSize: number, firstTimeSenderDelay: boolean, noCustomPassword: boolean): Promise<void> {
 		const fn: string = "AccountSettingsSave"
@@ -1745,6 +3331,89 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// AccountImpersonate starts a webmail session as an account, for troubleshooting.
+	// The returned single-use token must be opened within 1 minute, at
+	// "impersonate?token=..." of the webmail interface, e.g. at webmailPath on the
+	// same host. The session ends after the given number of minutes, at most 8
+	// hours. With readOnly, no changes can be made through the session. The start of
+	// the session and all API calls made through it are recorded in the audit log, and
+	// the webmail interface shows a banner while impersonating.
+	async AccountImpersonate(accountName: string, readOnly: boolean, minutes: number): Promise<[string, string]> {
+		const fn: string = "AccountImpersonate"
+		const paramTypes: string[][] = [["string"],["bool"],["int32"]]
+		const returnTypes: string[][] = [["string"],["string"]]
+		const params: any[] = [accountName, readOnly, minutes]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [string, string]
+	}
+
+	// SignupRequests returns all self-service signup requests, most recent first.
+	async SignupRequests(): Promise<SignupRequest[] | null> {
+		const fn: string = "SignupRequests"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","SignupRequest"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SignupRequest[] | null
+	}
+
+	// SignupApprove approves a pending signup request, creating the account and
+	// notifying the requester.
+	async SignupApprove(id: number): Promise<void> {
+		const fn: string = "SignupApprove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [id]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// SignupReject rejects a pending signup request, notifying the requester with
+	// the optional reason.
+	async SignupReject(id: number, reason: string): Promise<void> {
+		const fn: string = "SignupReject"
+		const paramTypes: string[][] = [["int64"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [id, reason]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// SignupInvites returns all signup invitations, most recent first.
+	async SignupInvites(): Promise<SignupInvite[] | null> {
+		const fn: string = "SignupInvites"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","SignupInvite"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SignupInvite[] | null
+	}
+
+	// SignupInviteAdd adds an invitation for signups at a domain, valid for the
+	// given number of days. The token is only returned once, the invitation link is
+	// "?invite=..." at signupPath.
+	async SignupInviteAdd(domainName: string, maxUses: number, days: number, note: string): Promise<[string, string]> {
+		const fn: string = "SignupInviteAdd"
+		const paramTypes: string[][] = [["string"],["int32"],["int32"],["string"]]
+		const returnTypes: string[][] = [["string"],["string"]]
+		const params: any[] = [domainName, maxUses, days, note]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [string, string]
+	}
+
+	// SignupInviteRemove removes a signup invitation.
+	async SignupInviteRemove(id: number): Promise<void> {
+		const fn: string = "SignupInviteRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [id]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DomainSignupSave saves the self-service signup settings for a domain. A nil
+	// signup disables signups for the domain.
+	async DomainSignupSave(domainName: string, signup: DomainSignup | null): Promise<void> {
+		const fn: string = "DomainSignupSave"
+		const paramTypes: string[][] = [["string"],["nullable","DomainSignup"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName, signup]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// ClientConfigsDomain returns configurations for email clients, IMAP and
 	// Submission (SMTP) for the domain.
 	async ClientConfigsDomain(domain: string): Promise<ClientConfigs> {
@@ -1793,7 +3462,18 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
-	// QueueList returns the messages currently in the outgoing queue.
+	// QueueHoldRuleResume removes a hold rule and takes matching messages in the
+	// queue off hold, unless they also match another hold rule.
+	async QueueHoldRuleResume(holdRuleID: number): Promise<number> {
+		const fn: string = "QueueHoldRuleResume"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [holdRuleID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// QueueList returns the messages currently in the outgoing queue. SMTP
+	// transcripts are not included, see QueueMsgTranscript.
 	async QueueList(filter: Filter, sort: Sort): Promise<Msg[] | null> {
 		const fn: string = "QueueList"
 		const paramTypes: string[][] = [["Filter"],["Sort"]]
@@ -1802,6 +3482,26 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Msg[] | null
 	}
 
+	// QueueCount returns the number of messages in the outgoing queue matching
+	// filter, ignoring its Max.
+	async QueueCount(filter: Filter): Promise<number> {
+		const fn: string = "QueueCount"
+		const paramTypes: string[][] = [["Filter"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [filter]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// QueueMsgTranscript returns the result of the most recent delivery attempt of
+	// a message in the queue that has an SMTP transcript.
+	async QueueMsgTranscript(id: number): Promise<MsgResult> {
+		const fn: string = "QueueMsgTranscript"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = [["MsgResult"]]
+		const params: any[] = [id]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MsgResult
+	}
+
 	// QueueNextAttemptSet sets a new time for next delivery attempt of matching
 	// messages from the queue.
 	async QueueNextAttemptSet(filter: Filter, minutes: number): Promise<number> {
@@ -1869,6 +3569,17 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
 	}
 
+	// QueueMsgRewrite changes the recipient, transport, and/or the From and Subject
+	// headers of a message in the queue, and schedules it for immediate delivery. The
+	// changes are kept with the message as audit trail.
+	async QueueMsgRewrite(id: number, rewrite: MsgRewrite): Promise<Msg> {
+		const fn: string = "QueueMsgRewrite"
+		const paramTypes: string[][] = [["int64"],["MsgRewrite"]]
+		const returnTypes: string[][] = [["Msg"]]
+		const params: any[] = [id, rewrite]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Msg
+	}
+
 	// RetiredList returns messages retired from the queue (delivery could
 	// have succeeded or failed).
 	async RetiredList(filter: RetiredFilter, sort: RetiredSort): Promise<MsgRetired[] | null> {
@@ -1879,6 +3590,66 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MsgRetired[] | null
 	}
 
+	// QueueDeadList returns messages in the dead-letter queue, for which delivery
+	// failed permanently.
+	async QueueDeadList(filter: DeadFilter): Promise<MsgDead[] | null> {
+		const fn: string = "QueueDeadList"
+		const paramTypes: string[][] = [["DeadFilter"]]
+		const returnTypes: string[][] = [["[]","MsgDead"]]
+		const params: any[] = [filter]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MsgDead[] | null
+	}
+
+	// QueueDeadRecipientSet changes the recipient address of a message in the
+	// dead-letter queue.
+	async QueueDeadRecipientSet(id: number, address: string): Promise<void> {
+		const fn: string = "QueueDeadRecipientSet"
+		const paramTypes: string[][] = [["int64"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [id, address]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// QueueDeadRequeue adds matching messages from the dead-letter queue back to
+	// the queue for delivery.
+	async QueueDeadRequeue(filter: DeadFilter): Promise<number> {
+		const fn: string = "QueueDeadRequeue"
+		const paramTypes: string[][] = [["DeadFilter"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [filter]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// QueueDeadRemove removes matching messages from the dead-letter queue.
+	async QueueDeadRemove(filter: DeadFilter): Promise<number> {
+		const fn: string = "QueueDeadRemove"
+		const paramTypes: string[][] = [["DeadFilter"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [filter]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// QueueHostHealthList returns the delivery health of remote hosts. Hosts in a
+	// cooldown period after failures are tried after other hosts with the same MX
+	// preference.
+	async QueueHostHealthList(): Promise<HostHealth[] | null> {
+		const fn: string = "QueueHostHealthList"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","HostHealth"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as HostHealth[] | null
+	}
+
+	// QueueHostHealthReset removes the delivery health record for a remote host,
+	// ending its cooldown period.
+	async QueueHostHealthReset(host: string): Promise<void> {
+		const fn: string = "QueueHostHealthReset"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [host]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// HookQueueSize returns the number of webhooks still to be delivered.
 	async HookQueueSize(): Promise<number> {
 		const fn: string = "HookQueueSize"
@@ -2158,6 +3929,49 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Dynamic
 	}
 
+	// ConfigDynamicGet returns the dynamic config, as structured data and in
+	// domains.conf format, with a hash to pass to ConfigDynamicApply.
+	async ConfigDynamicGet(): Promise<DynamicConfig> {
+		const fn: string = "ConfigDynamicGet"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["DynamicConfig"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DynamicConfig
+	}
+
+	// ConfigDynamicValidate returns the errors for the config as it would be
+	// checked by ConfigDynamicApply. No errors means the config is valid.
+	async ConfigDynamicValidate(c: Dynamic): Promise<string[] | null> {
+		const fn: string = "ConfigDynamicValidate"
+		const paramTypes: string[][] = [["Dynamic"]]
+		const returnTypes: string[][] = [["[]","string"]]
+		const params: any[] = [c]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string[] | null
+	}
+
+	// ConfigDynamicDiff returns the changes to domains.conf that applying the config
+	// would make, as unified diff. Empty if there are no changes.
+	async ConfigDynamicDiff(c: Dynamic): Promise<string> {
+		const fn: string = "ConfigDynamicDiff"
+		const paramTypes: string[][] = [["Dynamic"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [c]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// ConfigDynamicApply validates the config, and writes and activates it.
+	// baseHash must be the hash from ConfigDynamicGet for the config the changes are
+	// based on, the call fails if the config has been changed since. If the written
+	// domains.conf cannot be loaded, the previous config is restored. The new config
+	// is returned.
+	async ConfigDynamicApply(baseHash: string, c: Dynamic): Promise<DynamicConfig> {
+		const fn: string = "ConfigDynamicApply"
+		const paramTypes: string[][] = [["string"],["Dynamic"]]
+		const returnTypes: string[][] = [["DynamicConfig"]]
+		const params: any[] = [baseHash, c]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DynamicConfig
+	}
+
 	// AccountRoutesSave saves routes for an account.
 	async AccountRoutesSave(accountName: string, routes: Route[] | null): Promise<void> {
 		const fn: string = "AccountRoutesSave"
@@ -2176,6 +3990,69 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// AccountOutgoingFooterSave saves the footer added to outgoing messages of an
+	// account. A nil footer removes it.
+	async AccountOutgoingFooterSave(accountName: string, footer: OutgoingFooter | null): Promise<void> {
+		const fn: string = "AccountOutgoingFooterSave"
+		const paramTypes: string[][] = [["string"],["nullable","OutgoingFooter"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName, footer]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DomainOutgoingFooterSave saves the footer added to outgoing messages from a
+	// domain. A nil footer removes it.
+	async DomainOutgoingFooterSave(domainName: string, footer: OutgoingFooter | null): Promise<void> {
+		const fn: string = "DomainOutgoingFooterSave"
+		const paramTypes: string[][] = [["string"],["nullable","OutgoingFooter"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName, footer]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AccountSpamActionsSave saves the spam actions of the junk filter of an
+	// account. The account must have a junk filter. Zero values are unset, falling
+	// back to the spam actions of the domain of the account.
+	async AccountSpamActionsSave(accountName: string, spamActions: SpamActions): Promise<void> {
+		const fn: string = "AccountSpamActionsSave"
+		const paramTypes: string[][] = [["string"],["SpamActions"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [accountName, spamActions]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DomainSpamActionsSave saves the default spam actions for accounts with the
+	// domain as default domain. A nil value removes them.
+	async DomainSpamActionsSave(domainName: string, spamActions: SpamActions | null): Promise<void> {
+		const fn: string = "DomainSpamActionsSave"
+		const paramTypes: string[][] = [["string"],["nullable","SpamActions"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName, spamActions]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DomainSpamtrapsSave saves the localparts of spamtrap addresses of a domain.
+	// Messages to spamtraps are not delivered, but update the server-wide sender
+	// reputation and train the shared junk filter of the domain.
+	async DomainSpamtrapsSave(domainName: string, localparts: string[] | null): Promise<void> {
+		const fn: string = "DomainSpamtrapsSave"
+		const paramTypes: string[][] = [["string"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName, localparts]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DomainMessageTemplatesSave saves the message templates of a domain, shared
+	// with all accounts with an address in the domain for composing messages in the
+	// webmail.
+	async DomainMessageTemplatesSave(domainName: string, templates: MessageTemplate[] | null): Promise<void> {
+		const fn: string = "DomainMessageTemplatesSave"
+		const paramTypes: string[][] = [["string"],["[]","MessageTemplate"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName, templates]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// RoutesSave saves global routes.
 	async RoutesSave(routes: Route[] | null): Promise<void> {
 		const fn: string = "RoutesSave"
@@ -2255,6 +4132,26 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// DomainDKIMRotation returns the state of automatic DKIM key rotation for a
+	// domain, with DNS changes the administrator must make and the rotation history.
+	async DomainDKIMRotation(domainName: string): Promise<DKIMRotationStatus> {
+		const fn: string = "DomainDKIMRotation"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["DKIMRotationStatus"]]
+		const params: any[] = [domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DKIMRotationStatus
+	}
+
+	// DomainDKIMRecordsRemoved marks the DNS records of old DKIM selectors
+	// removed by rotation as removed from DNS by the administrator.
+	async DomainDKIMRecordsRemoved(domainName: string): Promise<void> {
+		const fn: string = "DomainDKIMRecordsRemoved"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// DomainDKIMRemove removes a DKIM selector for a domain.
 	async DomainDKIMRemove(domainName: string, selector: string): Promise<void> {
 		const fn: string = "DomainDKIMRemove"
@@ -2326,6 +4223,74 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// AliasListSave saves the distribution list settings of an alias. PostPolicy is
+	// one of "members", "anyone", "moderated", or empty to use PostPublic. Owners are
+	// addresses of local accounts that can manage the members.
+	async AliasListSave(aliaslp: string, domainName: string, postPolicy: string, listHeaders: boolean, owners: string[] | null): Promise<void> {
+		const fn: string = "AliasListSave"
+		const paramTypes: string[][] = [["string"],["string"],["string"],["bool"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliaslp, domainName, postPolicy, listHeaders, owners]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// AliasMailingListSave saves the mailing list settings of an alias. With a nil
+	// list, the alias is no longer a mailing list.
+	async AliasMailingListSave(aliaslp: string, domainName: string, list: AliasList | null): Promise<void> {
+		const fn: string = "AliasMailingListSave"
+		const paramTypes: string[][] = [["string"],["string"],["nullable","AliasList"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliaslp, domainName, list]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// ListSubscribers returns the subscribers of a mailing list, including those that
+	// haven't confirmed their subscription yet.
+	async ListSubscribers(aliaslp: string, domainName: string): Promise<Subscriber[] | null> {
+		const fn: string = "ListSubscribers"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = [["[]","Subscriber"]]
+		const params: any[] = [aliaslp, domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Subscriber[] | null
+	}
+
+	// ListSubscriberAdd adds a confirmed subscriber to a mailing list.
+	async ListSubscriberAdd(aliaslp: string, domainName: string, address: string, digest: boolean): Promise<void> {
+		const fn: string = "ListSubscriberAdd"
+		const paramTypes: string[][] = [["string"],["string"],["string"],["bool"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliaslp, domainName, address, digest]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// ListSubscriberRemove removes a subscriber from a mailing list.
+	async ListSubscriberRemove(aliaslp: string, domainName: string, subscriberID: number): Promise<void> {
+		const fn: string = "ListSubscriberRemove"
+		const paramTypes: string[][] = [["string"],["string"],["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [aliaslp, domainName, subscriberID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// ListArchive returns the archived messages of a mailing list, most recent
+	// first.
+	async ListArchive(aliaslp: string, domainName: string): Promise<Message[] | null> {
+		const fn: string = "ListArchive"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = [["[]","Message"]]
+		const params: any[] = [aliaslp, domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Message[] | null
+	}
+
+	// ListArchiveMessage returns an archived message of a mailing list as text.
+	async ListArchiveMessage(aliaslp: string, domainName: string, messageID: number): Promise<string> {
+		const fn: string = "ListArchiveMessage"
+		const paramTypes: string[][] = [["string"],["string"],["int64"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [aliaslp, domainName, messageID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
 	async TLSPublicKeys(accountOpt: string): Promise<TLSPublicKey[] | null> {
 		const fn: string = "TLSPublicKeys"
 		const paramTypes: string[][] = [["string"]]
@@ -2341,6 +4306,84 @@ export class Client {
 		const params: any[] = [accountName, limit]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as LoginAttempt[] | null
 	}
+
+	// AuditList returns events from the audit log matching filter, most recent first.
+	// If limit is greater than 0, at most limit events are returned.
+	async AuditList(filter: AuditFilter, limit: number): Promise<AuditEvent[] | null> {
+		const fn: string = "AuditList"
+		const paramTypes: string[][] = [["AuditFilter"],["int32"]]
+		const returnTypes: string[][] = [["[]","AuditEvent"]]
+		const params: any[] = [filter, limit]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as AuditEvent[] | null
+	}
+
+	// TLSCertsReload reloads the TLS certificates of listeners with static
+	// keys/certificates or certificate sources, returning the names of the listeners
+	// with reloaded certificates.
+	async TLSCertsReload(): Promise<string[] | null> {
+		const fn: string = "TLSCertsReload"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","string"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string[] | null
+	}
+
+	// StorageUsage returns the storage use of accounts relative to their quota,
+	// largest first. If limit is greater than 0, at most limit accounts are returned.
+	async StorageUsage(limit: number): Promise<QuotaStatus[] | null> {
+		const fn: string = "StorageUsage"
+		const paramTypes: string[][] = [["int32"]]
+		const returnTypes: string[][] = [["[]","QuotaStatus"]]
+		const params: any[] = [limit]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as QuotaStatus[] | null
+	}
+
+	// DomainMTASTSPolicySave saves the MTA-STS policy for a domain with the given
+	// mode, max age and MX hosts/patterns. A new policy ID is set if the policy
+	// changed. For domains with DNS provisioning, the MTA-STS DNS record is updated
+	// with the new policy ID. The (new) policy ID is returned.
+	async DomainMTASTSPolicySave(domainName: string, mode: Mode, maxAge: number, mx: string[] | null): Promise<string> {
+		const fn: string = "DomainMTASTSPolicySave"
+		const paramTypes: string[][] = [["string"],["Mode"],["int64"],["[]","string"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [domainName, mode, maxAge, mx]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// DomainMTASTSRollout returns the state of the staged rollout of the MTA-STS
+	// policy for a domain, based on the received TLS reports.
+	async DomainMTASTSRollout(domainName: string): Promise<MTASTSRollout> {
+		const fn: string = "DomainMTASTSRollout"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["MTASTSRollout"]]
+		const params: any[] = [domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MTASTSRollout
+	}
+
+	// DomainMTASTSPromote moves the MTA-STS policy for a domain to the next mode of
+	// the staged rollout: from no policy or mode none to testing, and from testing to
+	// enforce, the latter only if the TLS reports show the policy is ready. When
+	// enforcing, the max age is raised to at least a week. The new policy ID is
+	// returned.
+	async DomainMTASTSPromote(domainName: string): Promise<string> {
+		const fn: string = "DomainMTASTSPromote"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// DomainReadiness returns the readiness checklist for a domain, with DNS records
+	// to add and the status of each check. A check is started in the background if
+	// none is in progress and the latest result is older than a minute. Clients
+	// should poll while Checking is set or the domain is not ready.
+	async DomainReadiness(domainName: string): Promise<DomainReadiness> {
+		const fn: string = "DomainReadiness"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["DomainReadiness"]]
+		const params: any[] = [domainName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DomainReadiness
+	}
 }
 
 export const defaultBaseURL = (function() {
@@ -0,0 +1,91 @@
+package webadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webauth"
+)
+
+// Methods that only read, but are not marked as read in apiAccess because they
+// are not for auditors. Not recorded in the audit log.
+var auditSkip = map[string]bool{
+	"ConfigFiles":      true,
+	"Transports":       true,
+	"HookList":         true,
+	"HookRetiredList":  true,
+	"AdminUsers":       true,
+	"AdminTOTPEnabled": true,
+	"AdminPasskeys":    true,
+	"AuditList":        true,
+}
+
+// auditKind returns the kind of audit event for an API method that makes changes.
+func auditKind(method string) string {
+	switch {
+	case strings.HasPrefix(method, "Queue"), strings.HasPrefix(method, "Hook"):
+		return store.AuditQueue
+	case strings.HasSuffix(method, "SetPassword"), strings.Contains(method, "TOTP"), strings.Contains(method, "Passkey"):
+		return store.AuditPassword
+	case method == "AccountAdd", method == "AccountRemove", method == "AccountsImport", method == "SignupApprove", method == "SignupReject":
+		return store.AuditAccount
+	case method == "AccountImpersonate":
+		return store.AuditImpersonate
+	}
+	return store.AuditConfig
+}
+
+// auditCall serves the API call with apiHandler, and records an audit event for
+// methods that make changes. Parameters are not recorded, they can hold
+// passwords, but the account a call applies to is.
+func auditCall(ctx context.Context, log mlog.Log, apiHandler http.Handler, isForwarded bool, adminUser string, w http.ResponseWriter, r *http.Request) {
+	method := strings.TrimPrefix(r.URL.Path, "/api/")
+	ma := apiAccess[method]
+	// Logins are recorded with the login attempts.
+	if ma.all || ma.read || auditSkip[method] || method == "" || webauth.IsLoginPath(r.URL.Path) {
+		apiHandler.ServeHTTP(w, r)
+		return
+	}
+
+	var account string
+	buf, err := io.ReadAll(http.MaxBytesReader(w, r.Body, 10*1024*1024))
+	r.Body = io.NopCloser(bytes.NewReader(buf))
+	if err == nil {
+		var req struct {
+			Params []json.RawMessage `json:"params"`
+		}
+		if json.Unmarshal(buf, &req) == nil {
+			index := -1
+			if method == "AccountAdd" {
+				index = 0
+			}
+			for _, p := range ma.params {
+				if p.kind == paramAccount {
+					index = p.index
+				}
+			}
+			if index >= 0 && index < len(req.Params) {
+				json.Unmarshal(req.Params[index], &account)
+			}
+		}
+	}
+
+	actor := "(admin)"
+	if adminUser != "" {
+		actor = "(admin:" + adminUser + ")"
+	}
+	event := store.AuditEvent{
+		Kind:     auditKind(method),
+		Account:  account,
+		Actor:    actor,
+		Protocol: "webadmin",
+		Action:   method,
+	}
+	webauth.AuditAPICall(ctx, log, isForwarded, apiHandler, w, r, event)
+}
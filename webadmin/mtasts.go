@@ -0,0 +1,249 @@
+package webadmin
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/admin"
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/mtasts"
+	"github.com/mjl-/mox/tlsrpt"
+	"github.com/mjl-/mox/tlsrptdb"
+)
+
+// Staged rollout of an MTA-STS policy: a policy in mode testing is served for a
+// while, and TLS reports about deliveries under that policy are evaluated. When
+// enough reports have been received without recent failures, the policy can be
+// promoted to mode enforce.
+const (
+	// Period of TLS reports evaluated for rollout.
+	mtastsRolloutPeriod = 30 * 24 * time.Hour
+	// Minimum number of days with TLS reports about the testing policy.
+	mtastsRolloutMinDays = 7
+	// Period before now that must not have failures in TLS reports.
+	mtastsRolloutQuiet = 7 * 24 * time.Hour
+	// Minimum max age for enforced policies, set when promoting.
+	mtastsEnforceMinMaxAge = 7 * 24 * time.Hour
+)
+
+// MTASTSRollout is the state of the staged rollout of the MTA-STS policy for a
+// domain, based on TLS reports from remote mail servers received in the past 30
+// days.
+type MTASTSRollout struct {
+	Policy *config.MTASTS // Currently configured policy, nil if none.
+
+	// Mode the policy can be promoted to ("testing" or "enforce"), empty if none,
+	// e.g. when the policy is already enforced.
+	NextMode string
+	// Whether the policy can be promoted to NextMode now.
+	Ready bool
+	// Explanations for the current state, e.g. requirements not yet met.
+	Reasons []string
+
+	TestingDays      int   // Days with TLS reports about the policy in mode testing.
+	TestingSuccess   int64 // Successful sessions in TLS reports about the policy in mode testing.
+	TestingFailure   int64 // Failed sessions in TLS reports about the policy in mode testing.
+	RecentFailure    int64 // Failed sessions in TLS reports for the past 7 days.
+	FailureTypes     map[tlsrpt.ResultType]int64
+	LastReportPeriod time.Time // End of period of most recent report, zero if none.
+}
+
+// mtastsPolicyMode returns the mode from the policy string in a TLS report, or
+// empty if not found.
+func mtastsPolicyMode(policyString []string) mtasts.Mode {
+	for _, s := range policyString {
+		k, v, ok := strings.Cut(s, ":")
+		if ok && strings.TrimSpace(k) == "mode" {
+			return mtasts.Mode(strings.TrimSpace(v))
+		}
+	}
+	return ""
+}
+
+// mtastsRollout evaluates the TLS reports for domain for promotion of its
+// MTA-STS policy.
+func mtastsRollout(ctx context.Context, d dns.Domain, now time.Time) (MTASTSRollout, error) {
+	domConf, ok := mox.Conf.Domain(d)
+	if !ok {
+		return MTASTSRollout{}, fmt.Errorf("%w: domain does not exist", admin.ErrRequest)
+	}
+	records, err := tlsrptdb.RecordsPeriodDomain(ctx, now.Add(-mtastsRolloutPeriod), now, d)
+	if err != nil {
+		return MTASTSRollout{}, fmt.Errorf("fetching tls reports: %v", err)
+	}
+	return mtastsRolloutEval(d, domConf, records, now), nil
+}
+
+func mtastsRolloutEval(d dns.Domain, domConf config.Domain, records []tlsrptdb.Record, now time.Time) MTASTSRollout {
+	r := MTASTSRollout{Policy: domConf.MTASTS}
+
+	days := map[string]bool{}
+	for _, record := range records {
+		for _, p := range record.Report.Policies {
+			if p.Policy.Type != tlsrpt.STS || mtastsPolicyMode(p.Policy.String) != mtasts.ModeTesting {
+				continue
+			}
+			if pd, err := dns.ParseDomain(p.Policy.Domain); err != nil || pd != d {
+				continue
+			}
+			dr := record.Report.DateRange
+			days[dr.Start.UTC().Format("20060102")] = true
+			if dr.End.After(r.LastReportPeriod) {
+				r.LastReportPeriod = dr.End
+			}
+			r.TestingSuccess += p.Summary.TotalSuccessfulSessionCount
+			r.TestingFailure += p.Summary.TotalFailureSessionCount
+			if dr.End.After(now.Add(-mtastsRolloutQuiet)) {
+				r.RecentFailure += p.Summary.TotalFailureSessionCount
+			}
+			for _, fd := range p.FailureDetails {
+				if r.FailureTypes == nil {
+					r.FailureTypes = map[tlsrpt.ResultType]int64{}
+				}
+				r.FailureTypes[fd.ResultType] += fd.FailedSessionCount
+			}
+		}
+	}
+	r.TestingDays = len(days)
+
+	addf := func(format string, args ...any) {
+		r.Reasons = append(r.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	if domConf.TLSRPT == nil {
+		addf("No TLS reporting address is configured for the domain. Configure one and publish the TLSRPT DNS record to receive reports needed for evaluating the policy.")
+	}
+
+	switch {
+	case r.Policy == nil:
+		r.NextMode = string(mtasts.ModeTesting)
+		r.Ready = true
+		addf("No MTA-STS policy is configured. Start with a policy in mode testing.")
+	case r.Policy.Mode == mtasts.ModeNone:
+		r.NextMode = string(mtasts.ModeTesting)
+		r.Ready = true
+		addf("The policy is in mode none. Move to mode testing to start receiving TLS reports about the policy.")
+	case r.Policy.Mode == mtasts.ModeEnforce:
+		addf("The policy is enforced.")
+	case r.Policy.Mode == mtasts.ModeTesting:
+		r.NextMode = string(mtasts.ModeEnforce)
+		r.Ready = true
+		if r.TestingDays < mtastsRolloutMinDays {
+			r.Ready = false
+			addf("Received TLS reports about the policy in mode testing for %d days, need at least %d days.", r.TestingDays, mtastsRolloutMinDays)
+		}
+		if r.RecentFailure > 0 {
+			r.Ready = false
+			addf("TLS reports for the past %d days have %d failed sessions. Resolve the failures and wait until reports show no failures.", int(mtastsRolloutQuiet/(24*time.Hour)), r.RecentFailure)
+		}
+		if r.Ready {
+			addf("TLS reports show no recent failures for the policy in mode testing. The policy can be enforced.")
+		}
+	}
+	return r
+}
+
+// mtastsPolicySave saves the MTA-STS policy for a domain. The policy ID is set to
+// a new value if the policy changed. For domains with DNS provisioning, the DNS
+// record with the policy ID is updated.
+func mtastsPolicySave(ctx context.Context, d dns.Domain, mode mtasts.Mode, maxAge time.Duration, mx []string) (policyID string, rerr error) {
+	switch mode {
+	case mtasts.ModeNone, mtasts.ModeTesting, mtasts.ModeEnforce:
+	default:
+		return "", fmt.Errorf("%w: invalid mode %q", admin.ErrRequest, mode)
+	}
+	if maxAge <= 0 {
+		return "", fmt.Errorf("%w: max age must be positive", admin.ErrRequest)
+	}
+	for i, s := range mx {
+		mx[i] = strings.TrimSpace(s)
+	}
+	mx = slices.DeleteFunc(mx, func(s string) bool { return s == "" })
+
+	var provision bool
+	err := admin.DomainSave(ctx, d.Name(), func(domain *config.Domain) error {
+		old := domain.MTASTS
+		if old != nil && old.Mode == mode && old.MaxAge == maxAge && slices.Equal(old.MX, mx) {
+			policyID = old.PolicyID
+			return nil
+		}
+		policyID = time.Now().UTC().Format("20060102T150405")
+		if old != nil && old.PolicyID == policyID {
+			// Changed twice within a second, policy ID must change.
+			policyID = time.Now().UTC().Add(time.Second).Format("20060102T150405")
+		}
+		domain.MTASTS = &config.MTASTS{
+			PolicyID: policyID,
+			Mode:     mode,
+			MaxAge:   maxAge,
+			MX:       mx,
+		}
+		provision = domain.DNSProvisioning != nil
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if provision {
+		// The new policy is served, so we can now publish the new policy ID.
+		if _, err := admin.DNSProvision(ctx, d); err != nil {
+			return policyID, fmt.Errorf("policy saved, but updating dns records: %w", err)
+		}
+	}
+	return policyID, nil
+}
+
+// DomainMTASTSPolicySave saves the MTA-STS policy for a domain with the given
+// mode, max age and MX hosts/patterns. A new policy ID is set if the policy
+// changed. For domains with DNS provisioning, the MTA-STS DNS record is updated
+// with the new policy ID. The (new) policy ID is returned.
+func (Admin) DomainMTASTSPolicySave(ctx context.Context, domainName string, mode mtasts.Mode, maxAge time.Duration, mx []string) (policyID string) {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	policyID, err = mtastsPolicySave(ctx, d, mode, maxAge, mx)
+	xcheckf(ctx, err, "saving mtasts policy")
+	return policyID
+}
+
+// DomainMTASTSRollout returns the state of the staged rollout of the MTA-STS
+// policy for a domain, based on the received TLS reports.
+func (Admin) DomainMTASTSRollout(ctx context.Context, domainName string) MTASTSRollout {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	r, err := mtastsRollout(ctx, d, time.Now())
+	xcheckf(ctx, err, "evaluating mtasts rollout")
+	return r
+}
+
+// DomainMTASTSPromote moves the MTA-STS policy for a domain to the next mode of
+// the staged rollout: from no policy or mode none to testing, and from testing to
+// enforce, the latter only if the TLS reports show the policy is ready. When
+// enforcing, the max age is raised to at least a week. The new policy ID is
+// returned.
+func (Admin) DomainMTASTSPromote(ctx context.Context, domainName string) (policyID string) {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	r, err := mtastsRollout(ctx, d, time.Now())
+	xcheckf(ctx, err, "evaluating mtasts rollout")
+	if r.NextMode == "" || !r.Ready {
+		xcheckuserf(ctx, fmt.Errorf("%s", strings.Join(r.Reasons, " ")), "policy cannot be promoted")
+	}
+
+	maxAge := 24 * time.Hour
+	var mx []string
+	if r.Policy != nil {
+		maxAge = r.Policy.MaxAge
+		mx = slices.Clone(r.Policy.MX)
+	}
+	if r.NextMode == string(mtasts.ModeEnforce) && maxAge < mtastsEnforceMinMaxAge {
+		maxAge = mtastsEnforceMinMaxAge
+	}
+	policyID, err = mtastsPolicySave(ctx, d, mtasts.Mode(r.NextMode), maxAge, mx)
+	xcheckf(ctx, err, "saving mtasts policy")
+	return policyID
+}
@@ -0,0 +1,163 @@
+package webadmin
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/mjl-/sherpa"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+// ReadinessItem is a check in the readiness checklist of a domain.
+type ReadinessItem struct {
+	Name   string // E.g. "MX" or "DKIM".
+	Status string // "ok", "warning" or "error".
+	Result
+}
+
+// DomainReadiness is the checklist of DNS records and configuration needed for a
+// domain to send and receive email, for onboarding new domains. The checks run in
+// the background. Clients poll for results, each poll starts a new check if the
+// previous one is older than a minute.
+type DomainReadiness struct {
+	Domain   string
+	Checked  time.Time // Zero if no check has completed yet.
+	Checking bool      // Whether a check is in progress.
+	Error    string    // Error for the last check, if any.
+	Ready    bool      // Whether all checks completed without errors.
+	Done     int       // Number of items with status "ok".
+	Items    []ReadinessItem
+	Records  []string // All DNS records for the domain, as also returned by DomainRecords.
+}
+
+// Latest readiness checklist by domain name. Only kept in memory.
+var readiness = struct {
+	sync.Mutex
+	domains map[string]*DomainReadiness
+}{domains: map[string]*DomainReadiness{}}
+
+// Checks older than this are started again when polled.
+const readinessMaxAge = time.Minute
+
+// readinessItems turns the result of a domain check into checklist items.
+func readinessItems(r CheckResult) []ReadinessItem {
+	l := []ReadinessItem{
+		{Name: "MX", Result: r.MX.Result},
+		{Name: "SPF", Result: r.SPF.Result},
+		{Name: "DKIM", Result: r.DKIM.Result},
+		{Name: "DMARC", Result: r.DMARC.Result},
+		{Name: "MTA-STS", Result: r.MTASTS.Result},
+		{Name: "TLSRPT for domain", Result: r.DomainTLSRPT.Result},
+		{Name: "TLSRPT for host", Result: r.HostTLSRPT.Result},
+		{Name: "Reverse DNS", Result: r.IPRev.Result},
+		{Name: "TLS", Result: r.TLS.Result},
+		{Name: "DNSSEC", Result: r.DNSSEC.Result},
+		{Name: "DANE", Result: r.DANE.Result},
+		{Name: "SRV records", Result: r.SRVConf.Result},
+		{Name: "Autoconfig", Result: r.Autoconf.Result},
+		{Name: "Autodiscover", Result: r.Autodiscover.Result},
+	}
+	for i, it := range l {
+		if len(it.Errors) > 0 {
+			l[i].Status = "error"
+		} else if len(it.Warnings) > 0 {
+			l[i].Status = "warning"
+		} else {
+			l[i].Status = "ok"
+		}
+	}
+	return l
+}
+
+// readinessCheck runs the domain check and generates the DNS records for the
+// domain, returning a sherpa error message if it fails.
+func readinessCheck(ctx context.Context, domain string) (items []ReadinessItem, records []string, rerr error) {
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(*sherpa.Error); ok {
+			rerr = fmt.Errorf("%s", err.Message)
+			return
+		}
+		panic(x)
+	}()
+
+	log := pkglog.WithContext(ctx)
+	resolver := dns.StrictResolver{Pkg: "check", Log: log.Logger}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	cr := checkDomain(ctx, resolver, dialer, domain)
+	records = DomainRecords(ctx, log, domain)
+	return readinessItems(cr), records, nil
+}
+
+// readinessRefresh starts a check for the domain in the background, unless one is
+// in progress or a recent result is available. Must be called with readiness
+// locked.
+func readinessRefresh(domain string) *DomainReadiness {
+	dr := readiness.domains[domain]
+	if dr == nil {
+		dr = &DomainReadiness{Domain: domain}
+		readiness.domains[domain] = dr
+	}
+	if dr.Checking || !dr.Checked.IsZero() && time.Since(dr.Checked) < readinessMaxAge {
+		return dr
+	}
+	dr.Checking = true
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.WithValue(mox.Shutdown, mlog.CidKey, mox.Cid()), time.Minute)
+		defer cancel()
+		defer logPanic(ctx)
+
+		items, records, err := readinessCheck(ctx, domain)
+		if err != nil {
+			pkglog.WithContext(ctx).Infox("checking domain readiness", err, slog.String("domain", domain))
+		}
+
+		readiness.Lock()
+		defer readiness.Unlock()
+		ndr := &DomainReadiness{Domain: domain, Checked: time.Now()}
+		if err != nil {
+			ndr.Error = err.Error()
+		} else {
+			ndr.Items = items
+			ndr.Records = records
+			ndr.Ready = true
+			for _, it := range items {
+				if it.Status == "ok" {
+					ndr.Done++
+				} else if it.Status == "error" {
+					ndr.Ready = false
+				}
+			}
+		}
+		readiness.domains[domain] = ndr
+	}()
+	return dr
+}
+
+// DomainReadiness returns the readiness checklist for a domain, with DNS records
+// to add and the status of each check. A check is started in the background if
+// none is in progress and the latest result is older than a minute. Clients
+// should poll while Checking is set or the domain is not ready.
+func (Admin) DomainReadiness(ctx context.Context, domainName string) DomainReadiness {
+	d, err := dns.ParseDomain(domainName)
+	xcheckuserf(ctx, err, "parsing domain")
+	if _, ok := mox.Conf.Domain(d); !ok {
+		panic(&sherpa.Error{Code: "user:notFound", Message: "domain not found"})
+	}
+
+	readiness.Lock()
+	defer readiness.Unlock()
+	dr := readinessRefresh(d.Name())
+	return *dr
+}
@@ -151,8 +151,13 @@ func badResponse(hresp *http.Response) error {
 //   - multipleFrom, if multiple from addresses were specified.
 //   - badFrom, if a from address was specified that isn't configured for the account.
 //   - noRecipients, if no recipients were specified.
+//   - recipientSuppressed, if all recipients are on the suppression list of the
+//     account. If only some are, the message is submitted for the other recipients,
+//     and the suppressed recipients have an error in their Submission.
 //   - messageLimitReached, if the outgoing message rate limit was reached.
 //   - recipientLimitReached, if the outgoing new recipient rate limit was reached.
+//   - sendingSuspended, if sending was suspended for the account after an anomaly in outgoing messages.
+//   - sendingThrottled, if sending is temporarily throttled after an anomaly in outgoing messages.
 //   - messageTooLarge, message larger than configured maximum size.
 //   - malformedMessageID, if MessageID is specified but invalid.
 //   - sentOverQuota, message submitted, but not stored in Sent mailbox due to quota reached.
@@ -160,6 +165,36 @@ func (c Client) Send(ctx context.Context, req SendRequest) (resp SendResult, err
 	return transact[SendResult](ctx, c, "Send", req)
 }
 
+// SendBulk composes a separate message for each recipient from the message
+// template with per-recipient substitutions, and submits them to the queue for
+// delivery.
+//
+// For each recipient, the result indicates whether the message was submitted, or
+// the reason it was not. Configure webhooks to receive updates about deliveries.
+//
+// The outgoing message rate limits of the account apply to the request as a whole,
+// no messages are submitted if the limits would be exceeded.
+//
+// Uploaded files in a multipart/form-data request are added to each message, like
+// for [Client.Send]. Recipients on the suppression list of the account get a
+// per-recipient error with code recipientSuppressed.
+//
+// Error codes:
+//
+//   - badAddress, if an email address is invalid.
+//   - missingBody, if no text and no html body was specified.
+//   - multipleFrom, if multiple from addresses were specified.
+//   - badFrom, if a from address was specified that isn't configured for the account.
+//   - noRecipients, if no recipients were specified.
+//   - tooManyRecipients, if more than 10000 recipients were specified.
+//   - messageLimitReached, if the outgoing message rate limit was reached.
+//   - recipientLimitReached, if the outgoing new recipient rate limit was reached.
+//   - sendingSuspended, if sending was suspended for the account after an anomaly in outgoing messages.
+//   - sendingThrottled, if sending is temporarily throttled after an anomaly in outgoing messages.
+func (c Client) SendBulk(ctx context.Context, req SendBulkRequest) (resp SendBulkResult, err error) {
+	return transact[SendBulkResult](ctx, c, "SendBulk", req)
+}
+
 // SuppressionList returns the addresses on the per-account suppression list.
 func (c Client) SuppressionList(ctx context.Context, req SuppressionListRequest) (resp SuppressionListResult, err error) {
 	return transact[SuppressionListResult](ctx, c, "SuppressionList", req)
@@ -63,8 +63,18 @@ deliveries are retried with backoff, each time doubling the interval between
 attempts, at 1m, 2m, 4m, 7.5m, 15m and unwards, until the last attempt after a
 16h wait period.
 
+If a signing key is configured for the webhook, the HTTP request also has a
+header "X-Mox-Webhook-Signature" with value "sha256=" followed by the
+hex-encoded HMAC-SHA256 of the request body, with the signing key as key. Use a
+constant-time comparison when verifying the signature.
+
 See [webhook.Outgoing] for the fields in a webhook for outgoing deliveries, and
-in particular [webhook.OutgoingEvent] for the types of events.
+in particular [webhook.OutgoingEvent] for the types of events. Webhooks for outgoing deliveries caused by a delivery attempt
+from the queue include details about the SMTP session with the remote server,
+such as its full SMTP response, TLS version and cipher suite, and whether TLS
+was verified with MTA-STS or DANE. The SMTP protocol transcript is only included
+when enabled in the webhook configuration. Which events cause a webhook to be
+sent can also be configured.
 
 Only the latest event for the delivery of a particular outgoing message will be
 delivered, any webhooks for that message still in the queue (after failure to
@@ -94,7 +104,8 @@ To keep spam complaints about your messages to a minimum, include links to
 unsubscribe from future messages without requiring further actions from the
 user, such as logins. Include an unsubscribe link in the footer, and include
 List-* message headers, such as List-Id, List-Unsubscribe and
-List-Unsubscribe-Post.
+List-Unsubscribe-Post. The SendBulk method adds the List-Unsubscribe and
+List-Unsubscribe-Post headers for you, and can spread out deliveries over time.
 
 # Webapi examples
 
@@ -112,7 +123,8 @@ Send a basic message:
 			{
 				"Address": "mox@localhost",
 				"QueueMsgID": 10010,
-				"FromID": "ZfV16EATHwKEufrSMo055Q"
+				"FromID": "ZfV16EATHwKEufrSMo055Q",
+				"Error": null
 			}
 		]
 	}
@@ -130,7 +142,36 @@ Send a message with files both from form upload and base64 included in JSON:
 			{
 				"Address": "mox@localhost",
 				"QueueMsgID": 10011,
-				"FromID": "yWiUQ6mvJND8FRPSmc9y5A"
+				"FromID": "yWiUQ6mvJND8FRPSmc9y5A",
+				"Error": null
+			}
+		]
+	}
+
+Send a personalized message to multiple recipients, one message per recipient,
+with placeholders replaced and one-click unsubscribe headers added:
+
+	$ curl --user mox@localhost:moxmoxmox \
+		--data request='{"Subject": "news for {{name}}", "Text": "hi {{name}}, unsubscribe at https://localhost/unsubscribe?id={{id}}", "Recipients": [{"Name": "mox", "Address": "mox@localhost", "Substitutions": {"id": "1"}}, {"Address": "other@localhost"}], "UnsubscribeURL": "https://localhost/unsubscribe?id={{id}}", "MessagesPerMinute": 60}' \
+		http://localhost:1080/webapi/v0/SendBulk
+	{
+		"Recipients": [
+			{
+				"Address": "mox@localhost",
+				"MessageID": "<f4kNpkkqWDpE8ODp7m6y5Q@localhost>",
+				"QueueMsgID": 10012,
+				"FromID": "vnMOmtdZBb2eK1RBSqkJcA",
+				"Error": null
+			},
+			{
+				"Address": "other@localhost",
+				"MessageID": "",
+				"QueueMsgID": 0,
+				"FromID": "",
+				"Error": {
+					"Code": "missingSubstitution",
+					"Message": "text: no value for placeholder \"id\""
+				}
 			}
 		]
 	}
@@ -290,8 +331,21 @@ Example webhook HTTP POST JSON body for successful outgoing delivery:
 		"WebhookQueued": "2024-03-27T00:00:00Z",
 		"SMTPCode": 250,
 		"SMTPEnhancedCode": "",
+		"BounceClass": "",
 		"Error": "",
-		"Extra": {}
+		"Extra": {},
+		"Recipient": "mjl@remote.example",
+		"Attempts": 1,
+		"MaxAttempts": 0,
+		"NextAttempt": null,
+		"RemoteMTA": "mx.remote.example (192.0.2.10)",
+		"SMTPResponse": [
+			"250 2.0.0 ok, queued as 4QlC1X2yW4z"
+		],
+		"TLS": "tls1.3 tls_aes_128_gcm_sha256",
+		"MTASTS": false,
+		"DANE": true,
+		"Transcript": ""
 	}
 
 Example webhook HTTP POST JSON body for failed delivery based on incoming DSN
@@ -309,10 +363,53 @@ message, with custom extra data fields (from original submission), and adding ad
 		"WebhookQueued": "2024-03-27T00:00:00Z",
 		"SMTPCode": 554,
 		"SMTPEnhancedCode": "5.4.0",
+		"BounceClass": "soft",
 		"Error": "timeout connecting to host",
 		"Extra": {
 			"userid": "456"
-		}
+		},
+		"Recipient": "mjl@remote.example",
+		"Attempts": 1,
+		"MaxAttempts": 0,
+		"NextAttempt": null,
+		"RemoteMTA": "",
+		"SMTPResponse": [],
+		"TLS": "",
+		"MTASTS": false,
+		"DANE": false,
+		"Transcript": ""
+	}
+
+Example webhook HTTP POST JSON body for a delayed delivery, with details about
+the SMTP session of the last delivery attempt, including the transcript:
+
+	{
+		"Version": 0,
+		"Event": "delayed",
+		"DSN": false,
+		"Suppressing": false,
+		"QueueMsgID": 103,
+		"FromID": "MDEyMzQ1Njc4OWFiY2RlZg",
+		"MessageID": "<QnxzgulZK51utga6agH_rg@mox.example>",
+		"Subject": "subject of original message",
+		"WebhookQueued": "2024-03-27T00:00:00Z",
+		"SMTPCode": 451,
+		"SMTPEnhancedCode": "4.7.1",
+		"BounceClass": "soft",
+		"Error": "transient: 451 4.7.1 greylisted, try again later",
+		"Extra": {},
+		"Recipient": "mjl@remote.example",
+		"Attempts": 2,
+		"MaxAttempts": 8,
+		"NextAttempt": "2024-03-27T00:15:00Z",
+		"RemoteMTA": "mx.remote.example (192.0.2.10)",
+		"SMTPResponse": [
+			"451 4.7.1 greylisted, try again later"
+		],
+		"TLS": "tls1.3 tls_aes_128_gcm_sha256",
+		"MTASTS": true,
+		"DANE": false,
+		"Transcript": "S: 220 mx.remote.example ESMTP\nC: EHLO mox.example\nS: 250-mx.remote.example\nS: 250 STARTTLS\nC: STARTTLS\nS: 220 2.0.0 go ahead\nC: EHLO mox.example\nS: 250 mx.remote.example\nC: MAIL FROM:<bounce+QnxzgulZK51utga6agH_rg@mox.example>\nS: 250 2.1.0 ok\nC: RCPT TO:<mjl@remote.example>\nS: 451 4.7.1 greylisted, try again later\n"
 	}
 
 Example JSON body for webhooks for incoming delivery of basic message:
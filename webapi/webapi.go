@@ -16,6 +16,7 @@ import (
 // for documentation.
 type Methods interface {
 	Send(ctx context.Context, request SendRequest) (response SendResult, err error)
+	SendBulk(ctx context.Context, request SendBulkRequest) (response SendBulkResult, err error)
 	SuppressionList(ctx context.Context, request SuppressionListRequest) (response SuppressionListResult, err error)
 	SuppressionAdd(ctx context.Context, request SuppressionAddRequest) (response SuppressionAddResult, err error)
 	SuppressionRemove(ctx context.Context, request SuppressionRemoveRequest) (response SuppressionRemoveResult, err error)
@@ -165,17 +166,139 @@ type MessageMeta struct {
 	DKIMVerifiedDomains []string // Verified domains from DKIM-signature in message. Can be different domain than used in addresses.
 	RemoteIP            string   // Where the message was delivered from.
 	MailboxName         string
+
+	// Results of authentication checks during delivery over SMTP. Nil for messages not
+	// delivered over SMTP, e.g. sent or imported messages, or messages delivered by
+	// older versions.
+	Auth *MessageAuth
+}
+
+// MessageAuth holds the results of authentication checks during delivery, as also
+// added in the Authentication-Results header. Domains are unicode.
+type MessageAuth struct {
+	IPRev          string // Reverse IP lookup: "pass", "fail", "temperror", "permerror".
+	IPRevAuthentic bool   // Whether the lookups were DNSSEC-verified.
+	SPF            string // "pass", "fail", "softfail", "neutral", "none", "temperror", "permerror".
+	SPFIdentity    string // "mailfrom" or "helo".
+	SPFDomain      string
+	SPFAuthentic   bool
+	DKIM           []MessageAuthDKIM // One per DKIM-Signature header.
+	DMARC          string            // "pass", "fail", "none", "temperror", "permerror".
+	DMARCDomain    string            // Domain of message From header.
+	DMARCPolicy    string            // Published policy: "none", "quarantine", "reject". Empty without record.
+	DMARCAuthentic bool
+	DMARCOverrides []string // Local policy overrides, e.g. "mailing_list", "trusted_forwarder".
+	ARCSealer      string   // Trusted ARC sealer that caused a DMARC failure to be overridden.
+	BIMI           string   // For messages passing DMARC. Empty if not evaluated.
+	BIMIDomain     string
+	DNSBLs         []MessageAuthDNSBL // Checked DNS blocklists, only for senders without reputation.
+}
+
+// MessageAuthDKIM is the verification result for a DKIM-Signature header.
+type MessageAuthDKIM struct {
+	Status    string // "pass", "fail", "neutral", "none", "policy", "temperror", "permerror".
+	Domain    string
+	Selector  string
+	Algorithm string // E.g. "rsa-sha256".
+	Identity  string // Optional "i=" identifier.
+	Authentic bool   // Whether the DKIM DNS record was DNSSEC-verified.
+	Error     string
+}
+
+// MessageAuthDNSBL is the result of a DNS blocklist check of the remote IP.
+type MessageAuthDNSBL struct {
+	Zone   string
+	Status string // "pass" for not listed, "fail" for listed, "temperror" or "skipped".
 }
 
 type SendResult struct {
 	MessageID   string       // "<random>@<domain>", as added by submitter or automatically generated during submission.
-	Submissions []Submission // Messages submitted to queue for delivery. In order of To, CC, BCC fields in request.
+	Submissions []Submission // Messages submitted to queue for delivery, and recipients that were skipped. In order of To, CC, BCC fields in request.
 }
 
 type Submission struct {
 	Address    string // From original recipient (to/cc/bcc).
-	QueueMsgID int64  // Of message added to delivery queue, later webhook calls reference this same ID.
+	QueueMsgID int64  // Of message added to delivery queue, later webhook calls reference this same ID. Zero if not submitted.
 	FromID     string // Unique ID used during delivery, later webhook calls reference this same FromID.
+
+	// If set, no message was submitted for this recipient. Code is
+	// "recipientSuppressed" for a recipient on the suppression list of the account.
+	Error *Error
+}
+
+// SendBulkRequest submits a message to many recipients, each getting their own
+// copy of the message with personalized contents.
+type SendBulkRequest struct {
+	// Template for the message for each recipient. Subject, Text and HTML can contain
+	// placeholders of the form "{{key}}", replaced with the per-recipient value for
+	// "key". The placeholders "{{name}}" and "{{address}}" are replaced with the name
+	// and address of the recipient, unless overridden. Values are HTML-escaped when
+	// substituted in HTML. To, CC and BCC must be empty, recipients are specified in
+	// Recipients. MessageID must be empty, each message gets a unique message-id.
+	Message
+
+	// Recipients to send the message to, each in a separate message. Required.
+	Recipients []BulkRecipient
+
+	// Metadata to associate with each delivery, like for [SendRequest]. Per-recipient
+	// values in BulkRecipient override these. Optional.
+	Extra map[string]string
+
+	// Additional custom headers, like for [SendRequest]. Placeholders are not
+	// replaced. Optional.
+	Headers [][2]string
+
+	// URL for unsubscribing from future messages, with optional placeholders, e.g.
+	// "https://example.com/unsubscribe?id={{id}}". Substituted values are
+	// query-escaped. If set, headers List-Unsubscribe and List-Unsubscribe-Post are
+	// added for one-click unsubscribe. Should be an https URL. Optional.
+	UnsubscribeURL string
+
+	// Email address for unsubscribe requests, with optional placeholders.
+	// Substituted values are percent-encoded, as for a mailto URI. If set, a mailto
+	// URI is added to the List-Unsubscribe header. Optional.
+	UnsubscribeAddress string
+
+	// If larger than zero, first delivery attempts are spread out at this many
+	// messages per minute, starting at FutureRelease or now. Optional.
+	MessagesPerMinute int
+
+	// Like for [SendRequest], added to each message. Optional.
+	AlternativeFiles []File
+	InlineFiles      []File
+	AttachedFiles    []File
+
+	// Like for [SendRequest]. Optional.
+	RequireTLS    *bool
+	FutureRelease *time.Time
+}
+
+// BulkRecipient is a recipient for a message sent with SendBulk.
+type BulkRecipient struct {
+	NameAddress
+
+	// Values for placeholders in the message template and unsubscribe URL/address.
+	Substitutions map[string]string
+
+	// Extra metadata for this delivery, merged with SendBulkRequest.Extra. Optional.
+	Extra map[string]string
+}
+
+type SendBulkResult struct {
+	Recipients []BulkRecipientResult // Status for each recipient, in order of request.
+}
+
+// BulkRecipientResult is the status for a single recipient of a SendBulk request.
+type BulkRecipientResult struct {
+	Address    string // From original recipient.
+	MessageID  string // Message-ID of the message to this recipient, if submitted.
+	QueueMsgID int64  // Of message added to delivery queue, zero if not submitted.
+	FromID     string // Unique ID used during delivery, if enabled for account.
+
+	// If set, the message for this recipient was not submitted. Code is one of the
+	// error codes of Send, "missingSubstitution" if a placeholder has no value, or
+	// "badAddress" if the unsubscribe address is invalid after substitution.
+	Error *Error
 }
 
 // Suppression is an address to which messages will not be delivered. Attempts to
@@ -196,6 +319,10 @@ type Suppression struct {
 
 	Manual bool
 	Reason string
+
+	// For automatically added suppressions, the classification of the delivery
+	// failure that caused it.
+	BounceClass webhook.BounceClass
 }
 
 type SuppressionListRequest struct{}
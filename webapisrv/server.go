@@ -16,10 +16,12 @@ import (
 	htmltemplate "html/template"
 	"io"
 	"log/slog"
+	"maps"
 	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"os"
 	"reflect"
 	"runtime/debug"
@@ -55,7 +57,7 @@ var (
 	metricSubmission = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mox_webapi_submission_total",
-			Help: "Webapi message submission results, known values (those ending with error are server errors): ok, badfrom, messagelimiterror, recipientlimiterror, queueerror, storesenterror, domaindisabled.",
+			Help: "Webapi message submission results, known values (those ending with error are server errors): ok, badfrom, messagelimiterror, recipientlimiterror, queueerror, storesenterror, domaindisabled, suspended, throttled.",
 		},
 		[]string{
 			"result",
@@ -429,10 +431,10 @@ func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}()
 
 	var err error
-	acc, la.AccountName, err = store.OpenEmailAuth(log, email, password, true)
+	acc, la.AccountName, err = store.OpenEmailAuth(log, email, password, "webapi", remoteIP, true)
 	if err != nil {
 		mox.LimiterFailedAuth.Add(remoteIP, t0, 1)
-		if errors.Is(err, mox.ErrDomainNotFound) || errors.Is(err, mox.ErrAddressNotFound) || errors.Is(err, store.ErrUnknownCredentials) || errors.Is(err, store.ErrLoginDisabled) {
+		if errors.Is(err, mox.ErrDomainNotFound) || errors.Is(err, mox.ErrAddressNotFound) || errors.Is(err, store.ErrUnknownCredentials) || errors.Is(err, store.ErrLoginDisabled) || errors.Is(err, store.ErrLoginLocked) || errors.Is(err, store.ErrPasswordExpired) || errors.Is(err, store.ErrAppPassword) {
 			log.Debug("bad http basic authentication credentials")
 			metricResults.WithLabelValues(fn, "badauth").Inc()
 			la.Result = store.AuthBadCredentials
@@ -440,6 +442,15 @@ func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			if errors.Is(err, store.ErrLoginDisabled) {
 				la.Result = store.AuthLoginDisabled
 				msg = "login is disabled for this account"
+			} else if errors.Is(err, store.ErrLoginLocked) {
+				la.Result = store.AuthLoginLocked
+				msg = err.Error()
+			} else if errors.Is(err, store.ErrPasswordExpired) {
+				la.Result = store.AuthPasswordExpired
+				msg = err.Error()
+			} else if errors.Is(err, store.ErrAppPassword) {
+				la.Result = store.AuthAppPasswordRequired
+				msg = err.Error()
 			}
 			w.Header().Set("WWW-Authenticate", "Basic realm=webapi")
 			http.Error(w, "401 - unauthorized - "+msg, http.StatusUnauthorized)
@@ -614,6 +625,12 @@ func xrandom(n int) []byte {
 }
 
 func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.SendResult, err error) {
+	return s.send(ctx, req, time.Time{})
+}
+
+// send composes and queues a message. If nextAttempt is not zero, the first
+// delivery attempt is scheduled for that time.
+func (s server) send(ctx context.Context, req webapi.SendRequest, nextAttempt time.Time) (resp webapi.SendResult, err error) {
 	// Similar between ../smtpserver/server.go:/submit\( and ../webmail/api.go:/MessageSubmit\( and ../webapisrv/server.go:/Send\(
 
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
@@ -655,9 +672,31 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 		return resp, webapi.Error{Code: "noRecipients", Message: "no recipients"}
 	}
 
+	// Delivery to recipients on the suppression list would fail in the queue. We
+	// don't submit for those recipients, and report them in the result. Only if all
+	// recipients are suppressed, we reject the message.
+	suppressed := make([]bool, len(recipients))
+	var queueRecipients []smtp.Path
+	for i, rcpt := range recipients {
+		sup, err := queue.SuppressionLookup(ctx, acc.Name, rcpt)
+		xcheckf(err, "looking up suppression for recipient")
+		if sup != nil {
+			suppressed[i] = true
+		} else {
+			queueRecipients = append(queueRecipients, rcpt)
+		}
+	}
+	if len(queueRecipients) == 0 {
+		metricSubmission.WithLabelValues("suppressed").Inc()
+		if len(recipients) == 1 {
+			return resp, webapi.Error{Code: "recipientSuppressed", Message: fmt.Sprintf("recipient %s is on suppression list", recipients[0].XString(true))}
+		}
+		return resp, webapi.Error{Code: "recipientSuppressed", Message: "all recipients are on suppression list"}
+	}
+
 	// Check outgoing message rate limit.
 	xdbread(ctx, acc, func(tx *bstore.Tx) {
-		msglimit, rcptlimit, err := acc.SendLimitReached(tx, recipients)
+		msglimit, rcptlimit, err := acc.SendLimitReached(tx, queueRecipients)
 		if msglimit >= 0 {
 			metricSubmission.WithLabelValues("messagelimiterror").Inc()
 			panic(webapi.Error{Code: "messageLimitReached", Message: "outgoing message rate limit reached"})
@@ -668,6 +707,17 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 		xcheckf(err, "checking send limit")
 	})
 
+	// Check for anomalies in outgoing messages, a sign of a compromised account.
+	err = webops.SendAnomalies(ctx, log, acc, queueRecipients, nil)
+	if errors.Is(err, webops.ErrSendSuspended) {
+		metricSubmission.WithLabelValues("suspended").Inc()
+		return resp, webapi.Error{Code: "sendingSuspended", Message: err.Error()}
+	} else if errors.Is(err, webops.ErrSendThrottled) {
+		metricSubmission.WithLabelValues("throttled").Inc()
+		return resp, webapi.Error{Code: "sendingThrottled", Message: err.Error()}
+	}
+	xcheckf(err, "checking for anomalies in outgoing messages")
+
 	// If we have a non-ascii localpart, we will be sending with smtputf8. We'll go
 	// full utf-8 then.
 	intl := func(l []smtp.Path) bool {
@@ -979,6 +1029,17 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 	cur = nil
 	xc.Flush()
 
+	size, changed, err := acc.ApplyOutgoingFooter(log, from.Address, dataFile)
+	xcheckf(err, "adding outgoing footer")
+	if changed {
+		xc.Size = size
+	}
+	size, changed, err = acc.ApplyOutgoingHeaderRules(log, from.Address, dataFile)
+	xcheckf(err, "applying outgoing header rules")
+	if changed {
+		xc.Size = size
+	}
+
 	// Add DKIM-Signature headers.
 	var msgPrefix string
 	fd := from.Address.Domain
@@ -986,7 +1047,7 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 	if confDom.Disabled {
 		xcheckuserf(mox.ErrDomainDisabled, "checking domain")
 	}
-	selectors := mox.DKIMSelectors(confDom.DKIM)
+	selectors := mox.DKIMSelectorsMessage(log, confDom.DKIM, acc.Name, from.Address, dataFile)
 	if len(selectors) > 0 {
 		dkimHeaders, err := dkim.Sign(ctx, log.Logger, from.Address.Localpart, fd, selectors, smtputf8, dataFile)
 		if err != nil {
@@ -1004,10 +1065,10 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 	if useFromID {
 		localpartBase = strings.SplitN(string(fromPath.Localpart), confDom.LocalpartCatchallSeparatorsEffective[0], 2)[0]
 	}
-	fromIDs := make([]string, len(recipients))
-	qml := make([]queue.Msg, len(recipients))
+	fromIDs := make([]string, len(queueRecipients))
+	qml := make([]queue.Msg, len(queueRecipients))
 	now := time.Now()
-	for i, rcpt := range recipients {
+	for i, rcpt := range queueRecipients {
 		fp := fromPath
 		if useFromID {
 			fromIDs[i] = xrandomID(16)
@@ -1017,7 +1078,7 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 		// Don't use per-recipient unique message prefix when multiple recipients are
 		// present, we want to keep the message identical.
 		var recvRcpt string
-		if len(recipients) == 1 {
+		if len(queueRecipients) == 1 {
 			recvRcpt = rcpt.XString(smtputf8)
 		}
 		rcptMsgPrefix := recvHdrFor(recvRcpt) + msgPrefix
@@ -1034,6 +1095,9 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 			qm.FutureReleaseRequest = "until;" + req.FutureRelease.Format(time.RFC3339)
 			// todo: possibly add a header to the message stored in the Sent mailbox to indicate it was scheduled for later delivery.
 		}
+		if !nextAttempt.IsZero() {
+			qm.NextAttempt = nextAttempt
+		}
 		qml[i] = qm
 	}
 	err = queue.Add(ctx, log, acc.Name, dataFile, qml...)
@@ -1120,13 +1184,17 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 		})
 	}
 
-	submissions := make([]webapi.Submission, len(qml))
-	for i, qm := range qml {
-		submissions[i] = webapi.Submission{
-			Address:    addresses[i].Address,
-			QueueMsgID: qm.ID,
-			FromID:     fromIDs[i],
+	submissions := make([]webapi.Submission, len(recipients))
+	var qi int // Index in qml.
+	for i, rcpt := range recipients {
+		submissions[i] = webapi.Submission{Address: addresses[i].Address}
+		if suppressed[i] {
+			submissions[i].Error = &webapi.Error{Code: "recipientSuppressed", Message: fmt.Sprintf("recipient %s is on suppression list", rcpt.XString(true))}
+			continue
 		}
+		submissions[i].QueueMsgID = qml[qi].ID
+		submissions[i].FromID = fromIDs[qi]
+		qi++
 	}
 	resp = webapi.SendResult{
 		MessageID:   m.MessageID,
@@ -1135,6 +1203,244 @@ func (s server) Send(ctx context.Context, req webapi.SendRequest) (resp webapi.S
 	return resp, nil
 }
 
+// Maximum number of recipients in a single SendBulk request.
+const bulkRecipientsMax = 10000
+
+func (s server) SendBulk(ctx context.Context, req webapi.SendBulkRequest) (resp webapi.SendBulkResult, err error) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	m := req.Message
+	if len(m.To) > 0 || len(m.CC) > 0 || len(m.BCC) > 0 {
+		xcheckuserf(errors.New("to, cc and bcc must be empty, specify recipients in Recipients"), "checking message")
+	}
+	if m.MessageID != "" {
+		xcheckuserf(errors.New("message-id must be empty, each message gets its own"), "checking message")
+	}
+	if m.Text == "" && m.HTML == "" {
+		return resp, webapi.Error{Code: "missingBody", Message: "at least text or html body required"}
+	}
+	if len(req.Recipients) == 0 {
+		return resp, webapi.Error{Code: "noRecipients", Message: "no recipients"}
+	} else if len(req.Recipients) > bulkRecipientsMax {
+		return resp, webapi.Error{Code: "tooManyRecipients", Message: fmt.Sprintf("more than %d recipients", bulkRecipientsMax)}
+	}
+	if req.MessagesPerMinute < 0 {
+		xcheckuserf(errors.New("must be >= 0"), "checking messages per minute")
+	}
+	if req.UnsubscribeURL != "" {
+		u, err := url.Parse(req.UnsubscribeURL)
+		if err == nil && u.Scheme != "https" && u.Scheme != "http" {
+			err = errors.New("scheme must be https or http")
+		}
+		xcheckuserf(err, "parsing unsubscribe url")
+	}
+
+	// Check the outgoing rate limits for all recipients at once, we don't want to
+	// stop halfway.
+	rcpts := make([]webapi.NameAddress, len(req.Recipients))
+	for i, r := range req.Recipients {
+		rcpts[i] = r.NameAddress
+	}
+	_, paths := xparseAddresses(rcpts)
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		msglimit, rcptlimit, err := acc.SendLimitReached(tx, paths)
+		if msglimit >= 0 {
+			metricSubmission.WithLabelValues("messagelimiterror").Inc()
+			panic(webapi.Error{Code: "messageLimitReached", Message: "outgoing message rate limit reached"})
+		} else if rcptlimit >= 0 {
+			metricSubmission.WithLabelValues("recipientlimiterror").Inc()
+			panic(webapi.Error{Code: "recipientLimitReached", Message: "outgoing new recipient rate limit reached"})
+		}
+		xcheckf(err, "checking send limit")
+	})
+
+	err = webops.SendAnomalies(ctx, reqInfo.Log, acc, paths, nil)
+	if errors.Is(err, webops.ErrSendSuspended) {
+		metricSubmission.WithLabelValues("suspended").Inc()
+		return resp, webapi.Error{Code: "sendingSuspended", Message: err.Error()}
+	} else if errors.Is(err, webops.ErrSendThrottled) {
+		metricSubmission.WithLabelValues("throttled").Inc()
+		return resp, webapi.Error{Code: "sendingThrottled", Message: err.Error()}
+	}
+	xcheckf(err, "checking for anomalies in outgoing messages")
+
+	start := time.Now()
+	if req.FutureRelease != nil {
+		start = *req.FutureRelease
+	}
+
+	resp.Recipients = make([]webapi.BulkRecipientResult, len(req.Recipients))
+	for i, r := range req.Recipients {
+		resp.Recipients[i] = webapi.BulkRecipientResult{Address: r.Address}
+
+		var nextAttempt time.Time
+		if req.MessagesPerMinute > 0 {
+			nextAttempt = start.Add(time.Duration(i) * time.Minute / time.Duration(req.MessagesPerMinute))
+		}
+		sreq, err := bulkRequest(req, r)
+		if err != nil {
+			xerr, ok := err.(webapi.Error)
+			if !ok {
+				xerr = webapi.Error{Code: "missingSubstitution", Message: err.Error()}
+			}
+			resp.Recipients[i].Error = &xerr
+			continue
+		}
+		sresp, xerr := s.sendRecipient(ctx, sreq, nextAttempt)
+		if xerr != nil {
+			resp.Recipients[i].Error = xerr
+			continue
+		}
+		resp.Recipients[i].MessageID = sresp.MessageID
+		resp.Recipients[i].QueueMsgID = sresp.Submissions[0].QueueMsgID
+		resp.Recipients[i].FromID = sresp.Submissions[0].FromID
+	}
+	return resp, nil
+}
+
+// sendRecipient sends a single message of a SendBulk request, returning errors
+// instead of aborting the entire request.
+func (s server) sendRecipient(ctx context.Context, req webapi.SendRequest, nextAttempt time.Time) (resp webapi.SendResult, rerr *webapi.Error) {
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(webapi.Error); ok {
+			rerr = &err
+			return
+		}
+		panic(x)
+	}()
+
+	resp, err := s.send(ctx, req, nextAttempt)
+	if err != nil {
+		if xerr, ok := err.(webapi.Error); ok {
+			return resp, &xerr
+		}
+		return resp, &webapi.Error{Code: "server", Message: err.Error()}
+	}
+	return resp, nil
+}
+
+// bulkRequest returns the request for a single recipient of a SendBulk request,
+// with placeholders replaced and unsubscribe headers added.
+func bulkRequest(req webapi.SendBulkRequest, r webapi.BulkRecipient) (webapi.SendRequest, error) {
+	values := map[string]string{
+		"name":    r.Name,
+		"address": r.Address,
+	}
+	maps.Copy(values, r.Substitutions)
+
+	m := req.Message
+	m.To = []webapi.NameAddress{r.NameAddress}
+	var err error
+	if m.Subject, err = bulkSubstitute(m.Subject, values, nil); err != nil {
+		return webapi.SendRequest{}, fmt.Errorf("subject: %w", err)
+	}
+	if m.Text, err = bulkSubstitute(m.Text, values, nil); err != nil {
+		return webapi.SendRequest{}, fmt.Errorf("text: %w", err)
+	}
+	if m.HTML, err = bulkSubstitute(m.HTML, values, htmltemplate.HTMLEscapeString); err != nil {
+		return webapi.SendRequest{}, fmt.Errorf("html: %w", err)
+	}
+
+	headers := slices.Clone(req.Headers)
+	var unsubs []string
+	if req.UnsubscribeURL != "" {
+		u, err := bulkSubstitute(req.UnsubscribeURL, values, url.QueryEscape)
+		if err != nil {
+			return webapi.SendRequest{}, fmt.Errorf("unsubscribe url: %w", err)
+		}
+		unsubs = append(unsubs, "<"+u+">")
+	}
+	if req.UnsubscribeAddress != "" {
+		a, err := bulkSubstitute(req.UnsubscribeAddress, values, mailtoEscape)
+		if err != nil {
+			return webapi.SendRequest{}, fmt.Errorf("unsubscribe address: %w", err)
+		}
+		// With escaped values, the address can still be invalid due to the template.
+		if _, err := smtp.ParseAddress(a); err != nil {
+			return webapi.SendRequest{}, webapi.Error{Code: "badAddress", Message: fmt.Sprintf("unsubscribe address: %v", err)}
+		}
+		unsubs = append(unsubs, "<mailto:"+a+">")
+	}
+	if len(unsubs) > 0 {
+		// ../rfc/2369:146
+		headers = append(headers, [2]string{"List-Unsubscribe", strings.Join(unsubs, ", ")})
+	}
+	if req.UnsubscribeURL != "" && strings.HasPrefix(req.UnsubscribeURL, "https:") {
+		// One-click unsubscribe, requires an https URI. ../rfc/8058:137
+		headers = append(headers, [2]string{"List-Unsubscribe-Post", "List-Unsubscribe=One-Click"})
+	}
+
+	var extra map[string]string
+	if len(req.Extra) > 0 || len(r.Extra) > 0 {
+		extra = map[string]string{}
+		maps.Copy(extra, req.Extra)
+		maps.Copy(extra, r.Extra)
+	}
+
+	sreq := webapi.SendRequest{
+		Message:          m,
+		Extra:            extra,
+		Headers:          headers,
+		AlternativeFiles: req.AlternativeFiles,
+		InlineFiles:      req.InlineFiles,
+		AttachedFiles:    req.AttachedFiles,
+		RequireTLS:       req.RequireTLS,
+		FutureRelease:    req.FutureRelease,
+	}
+	return sreq, nil
+}
+
+// bulkSubstitute replaces "{{key}}" placeholders in s with their values. If escape
+// is not nil, values are escaped with it. An error is returned for a placeholder
+// without value.
+func bulkSubstitute(s string, values map[string]string, escape func(string) string) (string, error) {
+	var b strings.Builder
+	for {
+		i := strings.Index(s, "{{")
+		if i < 0 {
+			break
+		}
+		n := strings.Index(s[i+2:], "}}")
+		if n < 0 {
+			break
+		}
+		key := strings.TrimSpace(s[i+2 : i+2+n])
+		v, ok := values[key]
+		if !ok {
+			return "", fmt.Errorf("no value for placeholder %q", key)
+		}
+		if escape != nil {
+			v = escape(v)
+		}
+		b.WriteString(s[:i])
+		b.WriteString(v)
+		s = s[i+2+n+2:]
+	}
+	b.WriteString(s)
+	return b.String(), nil
+}
+
+// mailtoEscape percent-encodes s for use in the local part of the address in a
+// mailto URI, so substituted values cannot add characters that are not allowed
+// in an address or that end the URI in a List-Unsubscribe header, RFC 6068.
+// Only letters, digits and characters that are safe in both are kept.
+func mailtoEscape(s string) string {
+	var b strings.Builder
+	for _, c := range []byte(s) {
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("-._~!$'*+", c) >= 0 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
 func (s server) SuppressionList(ctx context.Context, req webapi.SuppressionListRequest) (resp webapi.SuppressionListResult, err error) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	resp.Suppressions, err = queue.SuppressionList(ctx, reqInfo.Account.Name)
@@ -1294,6 +1600,33 @@ func (s server) MessageGet(ctx context.Context, req webapi.MessageGetRequest) (r
 		RemoteIP:            m.RemoteIP,
 		MailboxName:         mb.Name,
 	}
+	if a := m.Auth; a != nil {
+		ma := webapi.MessageAuth{
+			IPRev:          a.IPRev,
+			IPRevAuthentic: a.IPRevAuthentic,
+			SPF:            a.SPF,
+			SPFIdentity:    a.SPFIdentity,
+			SPFDomain:      a.SPFDomain,
+			SPFAuthentic:   a.SPFAuthentic,
+			DKIM:           []webapi.MessageAuthDKIM{},
+			DMARC:          a.DMARC,
+			DMARCDomain:    a.DMARCDomain,
+			DMARCPolicy:    a.DMARCPolicy,
+			DMARCAuthentic: a.DMARCAuthentic,
+			DMARCOverrides: a.DMARCOverrides,
+			ARCSealer:      a.ARCSealer,
+			BIMI:           a.BIMI,
+			BIMIDomain:     a.BIMIDomain,
+			DNSBLs:         []webapi.MessageAuthDNSBL{},
+		}
+		for _, d := range a.DKIM {
+			ma.DKIM = append(ma.DKIM, webapi.MessageAuthDKIM(d))
+		}
+		for _, d := range a.DNSBLs {
+			ma.DNSBLs = append(ma.DNSBLs, webapi.MessageAuthDNSBL(d))
+		}
+		meta.Auth = &ma
+	}
 
 	structure, err := queue.PartStructure(log, &p)
 	xcheckf(err, "parsing structure")
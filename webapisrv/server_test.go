@@ -359,6 +359,64 @@ func TestServer(t *testing.T) {
 
 	// todo: messageLimitReached, recipientLimitReached
 
+	// SendBulk, with substitutions, unsubscribe headers and spread out deliveries.
+	bulkReq := webapi.SendBulkRequest{
+		Message: webapi.Message{
+			Subject: "hi {{name}}",
+			Text:    "your code is {{code}}\n",
+			HTML:    "<p>code {{ code }} for {{address}}</p>",
+		},
+		Recipients: []webapi.BulkRecipient{
+			{NameAddress: webapi.NameAddress{Name: "a&b", Address: "mjl+bulk1@mox.example"}, Substitutions: map[string]string{"code": "<1>"}, Extra: map[string]string{"b": "1"}},
+			{NameAddress: webapi.NameAddress{Address: "mjl+bulk2@mox.example"}, Substitutions: map[string]string{"code": "2"}},
+			{NameAddress: webapi.NameAddress{Address: "mjl+bulk3@mox.example"}}, // Missing code.
+		},
+		Extra:              map[string]string{"a": "123"},
+		UnsubscribeURL:     "https://mox.example/unsubscribe?code={{code}}",
+		UnsubscribeAddress: "unsubscribe+{{code}}@mox.example",
+		MessagesPerMinute:  2,
+	}
+	bulkResp, err := client.SendBulk(ctxbg, bulkReq)
+	tcheckf(t, err, "send bulk")
+	tcompare(t, len(bulkResp.Recipients), 3)
+	br := bulkResp.Recipients
+	tcompare(t, br[0].Error == nil && br[1].Error == nil, true)
+	tcompare(t, br[0].MessageID != br[1].MessageID, true)
+	tcompare(t, br[1].QueueMsgID, br[0].QueueMsgID+1)
+	tcompare(t, br[2].Address, "mjl+bulk3@mox.example")
+	tcompare(t, br[2].QueueMsgID, int64(0))
+	tcompare(t, br[2].Error != nil && br[2].Error.Code == "missingSubstitution", true)
+
+	bulkMsgs, err := queue.List(ctxbg, queue.Filter{IDs: []int64{br[0].QueueMsgID, br[1].QueueMsgID}}, queue.Sort{Field: "Queued", Asc: true})
+	tcheckf(t, err, "list queued bulk messages")
+	tcompare(t, len(bulkMsgs), 2)
+	tcompare(t, bulkMsgs[0].Subject, "hi a&b")
+	tcompare(t, bulkMsgs[0].Extra, map[string]string{"a": "123", "b": "1"})
+	tcompare(t, bulkMsgs[1].NextAttempt.Sub(bulkMsgs[0].NextAttempt).Round(time.Second), 30*time.Second)
+	bulkBuf, err := os.ReadFile(bulkMsgs[0].MessagePath())
+	tcheckf(t, err, "read queued bulk message")
+	bulkPart, err := message.EnsurePart(log.Logger, false, bytes.NewReader(bulkBuf), int64(len(bulkBuf)))
+	tcheckf(t, err, "parse queued bulk message")
+	bulkHdr, err := bulkPart.Header()
+	tcheckf(t, err, "parse headers")
+	tcompare(t, bulkHdr.Get("List-Unsubscribe"), "<https://mox.example/unsubscribe?code=%3C1%3E>, <mailto:unsubscribe+%3C1%3E@mox.example>")
+	tcompare(t, bulkHdr.Get("List-Unsubscribe-Post"), "List-Unsubscribe=One-Click")
+	if !bytes.Contains(bulkBuf, []byte("code &lt;1&gt; for mjl+bulk1@mox.example")) {
+		t.Fatalf("html part without escaped substitution")
+	}
+
+	_, err = client.SendBulk(ctxbg, webapi.SendBulkRequest{Message: webapi.Message{Subject: "test", Text: "hi"}})
+	terrcode(t, err, "noRecipients")
+	_, err = client.SendBulk(ctxbg, webapi.SendBulkRequest{Message: webapi.Message{Subject: "test"}, Recipients: bulkReq.Recipients})
+	terrcode(t, err, "missingBody")
+	_, err = client.SendBulk(ctxbg, webapi.SendBulkRequest{Message: webapi.Message{Text: "hi"}, Recipients: []webapi.BulkRecipient{{NameAddress: webapi.NameAddress{Address: "not an address"}}}})
+	terrcode(t, err, "badAddress")
+	_, err = client.SendBulk(ctxbg, webapi.SendBulkRequest{Message: webapi.Message{Text: "hi", To: []webapi.NameAddress{{Address: "mjl@mox.example"}}}, Recipients: bulkReq.Recipients})
+	terrcode(t, err, "user")
+	bulkResp, err = client.SendBulk(ctxbg, webapi.SendBulkRequest{Message: webapi.Message{Text: "hi"}, Recipients: bulkReq.Recipients[1:2], UnsubscribeAddress: "unsubscribe {{code}}@mox.example"})
+	tcheckf(t, err, "send bulk with bad unsubscribe address")
+	tcompare(t, bulkResp.Recipients[0].Error != nil && bulkResp.Recipients[0].Error.Code == "badAddress", true)
+
 	// SuppressionList
 	supListRes, err := client.SuppressionList(ctxbg, webapi.SuppressionListRequest{})
 	tcheckf(t, err, "listing suppressions")
@@ -406,6 +464,30 @@ func TestServer(t *testing.T) {
 	supPresRes, err = client.SuppressionPresent(ctxbg, webapi.SuppressionPresentRequest{EmailAddress: "not an address"})
 	terrcode(t, err, "badAddress")
 
+	// Send to suppressed address is rejected.
+	_, err = client.Send(ctxbg, webapi.SendRequest{
+		Message: webapi.Message{
+			To:      []webapi.NameAddress{{Address: "remote.last@☺.localhost"}},
+			Subject: "test",
+			Text:    "hi",
+		},
+	})
+	terrcode(t, err, "recipientSuppressed")
+
+	// Only suppressed recipients are skipped when there are other recipients.
+	sendRes, err = client.Send(ctxbg, webapi.SendRequest{
+		Message: webapi.Message{
+			To:      []webapi.NameAddress{{Address: "remote.last@☺.localhost"}, {Address: "other@remote.example"}},
+			Subject: "test",
+			Text:    "hi",
+		},
+	})
+	tcheckf(t, err, "send with suppressed recipient")
+	tcompare(t, len(sendRes.Submissions), 2)
+	tcompare(t, sendRes.Submissions[0].Error != nil && sendRes.Submissions[0].Error.Code == "recipientSuppressed", true)
+	tcompare(t, sendRes.Submissions[0].QueueMsgID, int64(0))
+	tcompare(t, sendRes.Submissions[1].Error == nil && sendRes.Submissions[1].QueueMsgID != 0, true)
+
 	// SuppressionRemove
 	_, err = client.SuppressionRemove(ctxbg, webapi.SuppressionRemoveRequest{EmailAddress: "remote.LAST+more@☺.LocalHost"})
 	tcheckf(t, err, "remove suppressed address")
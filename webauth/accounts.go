@@ -14,8 +14,97 @@ var Accounts SessionAuth = accountSessionAuth{}
 
 type accountSessionAuth struct{}
 
-func (accountSessionAuth) login(ctx context.Context, log mlog.Log, username, password string) (valid, disabled bool, accName string, rerr error) {
-	acc, accName, err := store.OpenEmailAuth(log, username, password, true)
+func (accountSessionAuth) login(ctx context.Context, log mlog.Log, kind, username, password string) (valid, disabled bool, accName string, rerr error) {
+	acc, accName, err := store.OpenEmailAuth(log, username, password, "", nil, true)
+	if err != nil && errors.Is(err, store.ErrUnknownCredentials) {
+		return false, false, accName, nil
+	} else if err != nil && errors.Is(err, store.ErrPasswordExpired) && kind == "webaccount" {
+		// The password was valid. The account web interface is where a new password is set.
+		return true, false, accName, nil
+	} else if err != nil && (errors.Is(err, store.ErrLoginDisabled) || errors.Is(err, store.ErrLoginLocked) || errors.Is(err, store.ErrPasswordExpired)) {
+		return false, true, accName, err // Returning error, for its message.
+	} else if err != nil {
+		return false, false, accName, err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	return true, false, accName, nil
+}
+
+func (accountSessionAuth) recover(ctx context.Context, log mlog.Log, accountName, code string) (valid bool, rerr error) {
+	acc, err := store.OpenAccount(log, accountName, false)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	err = acc.RecoveryCodeUse(log, code)
+	if err != nil && errors.Is(err, store.ErrUnknownCredentials) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (accountSessionAuth) twoFactor(ctx context.Context, log mlog.Log, kind, accountName, code string) error {
+	acc, err := store.OpenAccount(log, accountName, false)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	if enabled, err := acc.TwoFactorEnabled(); err != nil {
+		return err
+	} else if !enabled {
+		if accConf, ok := acc.Conf(); !ok || !store.TwoFactorRequired(accConf) || kind == "webaccount" {
+			return nil
+		}
+		// Passkeys also satisfy the requirement, as second factor after the password.
+		if haspk, err := acc.HasPasskeys(); err != nil {
+			return err
+		} else if !haspk {
+			return store.ErrTwoFactorSetup
+		} else if code == "" {
+			return store.ErrTOTPRequired
+		}
+		return store.ErrUnknownCredentials
+	} else if code == "" {
+		return store.ErrTOTPRequired
+	}
+	return acc.TOTPVerify(code)
+}
+
+func (accountSessionAuth) passkeyCheck(ctx context.Context, log mlog.Log, kind, passkeyAccount string) (valid, disabled bool, accName string, rerr error) {
+	if passkeyAccount == "" {
+		return false, false, "", nil
+	}
+	acc, err := store.OpenAccount(log, passkeyAccount, true)
+	if err != nil && errors.Is(err, store.ErrAccountUnknown) {
+		return false, false, passkeyAccount, nil
+	} else if err != nil && errors.Is(err, store.ErrLoginDisabled) {
+		return false, true, passkeyAccount, err // Returning error, for its message.
+	} else if err != nil {
+		return false, false, passkeyAccount, err
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+	if err := store.CheckLoginLockout(passkeyAccount); err != nil {
+		return false, true, passkeyAccount, err
+	}
+	return true, false, passkeyAccount, nil
+}
+
+func (accountSessionAuth) oidcCheck(ctx context.Context, log mlog.Log, kind, address string) (valid, disabled bool, accName string, rerr error) {
+	acc, accName, _, err := store.OpenEmail(log, address, true)
 	if err != nil && errors.Is(err, store.ErrUnknownCredentials) {
 		return false, false, accName, nil
 	} else if err != nil && errors.Is(err, store.ErrLoginDisabled) {
@@ -27,11 +116,14 @@ func (accountSessionAuth) login(ctx context.Context, log mlog.Log, username, pas
 		err := acc.Close()
 		log.Check(err, "closing account")
 	}()
+	if err := store.CheckLoginLockout(accName); err != nil {
+		return false, true, accName, err
+	}
 	return true, false, accName, nil
 }
 
-func (accountSessionAuth) add(ctx context.Context, log mlog.Log, accountName string, loginAddress string) (sessionToken store.SessionToken, csrfToken store.CSRFToken, rerr error) {
-	return store.SessionAdd(ctx, log, accountName, loginAddress)
+func (accountSessionAuth) add(ctx context.Context, log mlog.Log, accountName, loginAddress, kind, remoteIP, userAgent string) (sessionToken store.SessionToken, csrfToken store.CSRFToken, rerr error) {
+	return store.SessionAdd(ctx, log, accountName, loginAddress, kind, remoteIP, userAgent)
 }
 
 func (accountSessionAuth) use(ctx context.Context, log mlog.Log, accountName string, sessionToken store.SessionToken, csrfToken store.CSRFToken) (loginAddress string, rerr error) {
@@ -4,7 +4,9 @@ import (
 	"context"
 	cryptorand "crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"strings"
 	"sync"
@@ -16,19 +18,38 @@ import (
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/totp"
 )
 
 // Admin is for admin logins, with authentication by password, and sessions only
 // stored in memory only, with lifetime 12 hour after last use, with a maximum of
 // 10 active sessions.
+//
+// Logins without username use the admin password from mox.conf, and get the name
+// AdminPasswordLogin. Logins with a username are for the admin users from
+// domains.conf, with names like "(admin:<username>)", see AdminSessionUser.
 var Admin SessionAuth = &adminSessionAuth{
 	sessions: map[store.SessionToken]adminSession{},
 }
 
+// AdminPasswordLogin is the name for sessions after logging in with the admin
+// password from mox.conf, with full access.
+const AdminPasswordLogin = "(admin)"
+
+// AdminSessionUser returns the admin user for the name of an admin session, or an
+// empty string for logins with the admin password.
+func AdminSessionUser(name string) string {
+	if s, ok := strings.CutPrefix(name, "(admin:"); ok {
+		return strings.TrimSuffix(s, ")")
+	}
+	return ""
+}
+
 // Good chance of fitting one working day.
 const adminSessionLifetime = 12 * time.Hour
 
 type adminSession struct {
+	name         string // AdminPasswordLogin, or with admin user.
 	sessionToken store.SessionToken
 	csrfToken    store.CSRFToken
 	expires      time.Time
@@ -37,18 +58,32 @@ type adminSession struct {
 type adminSessionAuth struct {
 	sync.Mutex
 	sessions map[store.SessionToken]adminSession
+
+	totpPending  []byte // Secret during TOTP setup, until confirmed.
+	totpLastStep int64  // Of last accepted TOTP code, to prevent replay.
 }
 
-func (a *adminSessionAuth) login(ctx context.Context, log mlog.Log, username, password string) (valid, disabled bool, name string, rerr error) {
+func (a *adminSessionAuth) login(ctx context.Context, log mlog.Log, kind, username, password string) (valid, disabled bool, name string, rerr error) {
 	a.Lock()
 	defer a.Unlock()
 
-	p := mox.ConfigDirPath(mox.Conf.Static.AdminPasswordFile)
-	buf, err := os.ReadFile(p)
-	if err != nil {
-		return false, false, "", fmt.Errorf("reading password file: %v", err)
+	var passwordhash string
+	name = AdminPasswordLogin
+	if username != "" {
+		au, ok := mox.Conf.AdminUser(username)
+		if !ok {
+			return false, false, "", nil
+		}
+		passwordhash = au.PasswordHash
+		name = "(admin:" + username + ")"
+	} else {
+		p := mox.ConfigDirPath(mox.Conf.Static.AdminPasswordFile)
+		buf, err := os.ReadFile(p)
+		if err != nil {
+			return false, false, "", fmt.Errorf("reading password file: %v", err)
+		}
+		passwordhash = strings.TrimSpace(string(buf))
 	}
-	passwordhash := strings.TrimSpace(string(buf))
 	// Transform with precis, if valid. ../rfc/8265:679
 	pw, err := precis.OpaqueString.String(password)
 	if err == nil {
@@ -58,10 +93,107 @@ func (a *adminSessionAuth) login(ctx context.Context, log mlog.Log, username, pa
 		return false, false, "", nil
 	}
 
-	return true, false, "(admin)", nil
+	return true, false, name, nil
+}
+
+// adminTOTPPath returns the path of the file with the TOTP secret for admin
+// logins, next to the admin password file.
+func adminTOTPPath() string {
+	return mox.ConfigDirPath(mox.Conf.Static.AdminPasswordFile) + ".totp"
+}
+
+// adminTOTPSecret returns the TOTP secret for admin logins, nil if not enabled.
+func adminTOTPSecret() ([]byte, error) {
+	buf, err := os.ReadFile(adminTOTPPath())
+	if err != nil && errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading totp file: %v", err)
+	}
+	return totp.DecodeSecret(string(buf))
+}
+
+func (a *adminSessionAuth) twoFactor(ctx context.Context, log mlog.Log, kind, accountName, code string) error {
+	a.Lock()
+	defer a.Unlock()
+
+	// Two-factor authentication is only available for the admin password.
+	if accountName != AdminPasswordLogin {
+		return nil
+	}
+
+	secret, err := adminTOTPSecret()
+	if err != nil {
+		return err
+	} else if secret == nil {
+		return nil
+	} else if code == "" {
+		return store.ErrTOTPRequired
+	}
+	step, ok := totp.Verify(secret, code, time.Now(), a.totpLastStep)
+	if !ok {
+		return store.ErrUnknownCredentials
+	}
+	a.totpLastStep = step
+	return nil
+}
+
+// AdminTOTPEnabled returns whether admin logins require a TOTP code.
+func AdminTOTPEnabled() (bool, error) {
+	secret, err := adminTOTPSecret()
+	return secret != nil, err
+}
+
+// AdminTOTPSetupStart returns a new secret for TOTP for admin logins, to be
+// confirmed with AdminTOTPSetupConfirm.
+func AdminTOTPSetupStart() []byte {
+	a := Admin.(*adminSessionAuth)
+	a.Lock()
+	defer a.Unlock()
+	a.totpPending = totp.NewSecret()
+	return a.totpPending
+}
+
+// AdminTOTPSetupConfirm verifies code against the secret from AdminTOTPSetupStart
+// and enables TOTP for admin logins by writing the secret to a file.
+func AdminTOTPSetupConfirm(code string) error {
+	a := Admin.(*adminSessionAuth)
+	a.Lock()
+	defer a.Unlock()
+	if a.totpPending == nil {
+		return fmt.Errorf("no two-factor authentication setup in progress")
+	}
+	step, ok := totp.Verify(a.totpPending, code, time.Now(), 0)
+	if !ok {
+		return store.ErrUnknownCredentials
+	}
+	if err := os.WriteFile(adminTOTPPath(), []byte(totp.EncodeSecret(a.totpPending)+"\n"), 0660); err != nil {
+		return fmt.Errorf("writing totp file: %v", err)
+	}
+	a.totpPending = nil
+	a.totpLastStep = step
+	return nil
+}
+
+// AdminTOTPRemove disables TOTP for admin logins.
+func AdminTOTPRemove() error {
+	a := Admin.(*adminSessionAuth)
+	a.Lock()
+	defer a.Unlock()
+	if err := os.Remove(adminTOTPPath()); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing totp file: %v", err)
+	}
+	return nil
+}
+
+func (a *adminSessionAuth) passkeyCheck(ctx context.Context, log mlog.Log, kind, passkeyAccount string) (valid, disabled bool, name string, rerr error) {
+	if passkeyAccount != "" {
+		return false, false, "", nil
+	}
+	return true, false, AdminPasswordLogin, nil
 }
 
-func (a *adminSessionAuth) add(ctx context.Context, log mlog.Log, accountName string, loginAddress string) (sessionToken store.SessionToken, csrfToken store.CSRFToken, rerr error) {
+func (a *adminSessionAuth) add(ctx context.Context, log mlog.Log, accountName, loginAddress, kind, remoteIP, userAgent string) (sessionToken store.SessionToken, csrfToken store.CSRFToken, rerr error) {
 	a.Lock()
 	defer a.Unlock()
 
@@ -95,7 +227,7 @@ func (a *adminSessionAuth) add(ctx context.Context, log mlog.Log, accountName st
 	csrfToken = store.CSRFToken(base64.RawURLEncoding.EncodeToString(csrfData[:]))
 
 	// Register session.
-	a.sessions[sessionToken] = adminSession{sessionToken, csrfToken, time.Now().Add(adminSessionLifetime)}
+	a.sessions[sessionToken] = adminSession{accountName, sessionToken, csrfToken, time.Now().Add(adminSessionLifetime)}
 	return sessionToken, csrfToken, nil
 }
 
@@ -110,6 +242,11 @@ func (a *adminSessionAuth) use(ctx context.Context, log mlog.Log, accountName st
 		return "", fmt.Errorf("session expired (after 12 hours inactivity)")
 	} else if csrfToken != "" && csrfToken != s.csrfToken {
 		return "", fmt.Errorf("mismatch between csrf and session tokens")
+	} else if accountName != s.name {
+		return "", fmt.Errorf("mismatch between session and user")
+	} else if _, ok := mox.Conf.AdminUser(AdminSessionUser(s.name)); s.name != AdminPasswordLogin && !ok {
+		delete(a.sessions, sessionToken)
+		return "", fmt.Errorf("admin user no longer exists")
 	}
 	s.expires = time.Now().Add(adminSessionLifetime)
 	a.sessions[sessionToken] = s
@@ -0,0 +1,47 @@
+package webauth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/mjl-/sherpa"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+// auditWriter keeps the start of the response, for finding whether the API call
+// failed.
+type auditWriter struct {
+	http.ResponseWriter
+	buf []byte
+}
+
+func (w *auditWriter) Write(buf []byte) (int, error) {
+	if n := min(len(buf), 4*1024-len(w.buf)); n > 0 {
+		w.buf = append(w.buf, buf[:n]...)
+	}
+	return w.ResponseWriter.Write(buf)
+}
+
+// AuditAPICall serves a sherpa API call with apiHandler, and records event in the
+// audit log, with the remote IP and the result of the call set.
+func AuditAPICall(ctx context.Context, log mlog.Log, isForwarded bool, apiHandler http.Handler, w http.ResponseWriter, r *http.Request, event store.AuditEvent) {
+	aw := &auditWriter{ResponseWriter: w}
+	apiHandler.ServeHTTP(aw, r)
+
+	// Responses with errors have a null result, so fit in the buffer. Long
+	// responses that are cut off are successful.
+	event.Result = "ok"
+	var resp struct {
+		Error *sherpa.Error `json:"error"`
+	}
+	if json.Unmarshal(aw.buf, &resp) == nil && resp.Error != nil {
+		event.Result = resp.Error.Message
+	}
+	if ip := RemoteIP(log, isForwarded, r); ip != nil {
+		event.RemoteIP = ip.String()
+	}
+	store.AuditAdd(ctx, log, event)
+}
@@ -0,0 +1,100 @@
+package webauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+)
+
+// ServeBranding serves the per-domain customization of the account and mail web
+// interfaces, for the domain matching the Host header of the request:
+// "branding.css", "branding.js" and "branding/logo". The HTML pages include the
+// CSS and JS, which are empty for domains without customization. Returns false if
+// the request is not for a branding path, so the caller can continue handling it.
+func ServeBranding(log mlog.Log, isForwarded bool, w http.ResponseWriter, r *http.Request) bool {
+	switch r.URL.Path {
+	case "/branding.css", "/branding.js", "/branding/logo":
+	default:
+		return false
+	}
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
+		return true
+	}
+
+	host, _ := RequestOrigin(isForwarded, r)
+	web, _ := mox.Conf.HostWeb(host)
+
+	h := w.Header()
+	// Configuration can change at any time, browsers must check with us.
+	h.Set("Cache-Control", "no-cache, max-age=0")
+
+	switch r.URL.Path {
+	case "/branding.css":
+		var b bytes.Buffer
+		if web.Color != "" {
+			fmt.Fprintf(&b, ":root { accent-color: %s; }\n", web.Color)
+			fmt.Fprintf(&b, "a, a:visited { color: %s !important; }\n", web.Color)
+			fmt.Fprintf(&b, "button { border-color: %s !important; }\n", web.Color)
+		}
+		if web.BackgroundColor != "" {
+			fmt.Fprintf(&b, "body { background-color: %s !important; }\n", web.BackgroundColor)
+		}
+		if web.CSSFile != "" {
+			buf, err := os.ReadFile(mox.ConfigDynamicDirPath(web.CSSFile))
+			if err != nil {
+				log.Errorx("reading branding css file", err)
+			} else {
+				b.Write(buf)
+			}
+		}
+		h.Set("Content-Type", "text/css; charset=utf-8")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b.Bytes()))
+
+	case "/branding.js":
+		// Fields for the frontend. JSON encoding escapes HTML special characters.
+		v := struct {
+			Name      string `json:",omitempty"`
+			LoginText string `json:",omitempty"`
+			Logo      bool   `json:",omitempty"`
+		}{web.Name, web.LoginText, web.LogoFile != ""}
+		buf, err := json.Marshal(v)
+		if err != nil {
+			http.Error(w, "500 - internal server error - "+err.Error(), http.StatusInternalServerError)
+			return true
+		}
+		h.Set("Content-Type", "application/javascript; charset=utf-8")
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader([]byte("window.moxBranding = "+string(buf)+"\n")))
+
+	case "/branding/logo":
+		if web.LogoFile == "" {
+			http.NotFound(w, r)
+			return true
+		}
+		p := mox.ConfigDynamicDirPath(web.LogoFile)
+		f, err := os.Open(p)
+		if err != nil {
+			log.Errorx("opening branding logo file", err)
+			http.NotFound(w, r)
+			return true
+		}
+		defer func() {
+			err := f.Close()
+			log.Check(err, "closing branding logo file")
+		}()
+		fi, err := f.Stat()
+		if err != nil {
+			http.Error(w, "500 - internal server error - "+err.Error(), http.StatusInternalServerError)
+			return true
+		}
+		// Content-Type is based on the file name extension.
+		http.ServeContent(w, r, p, fi.ModTime(), f)
+	}
+	return true
+}
@@ -0,0 +1,165 @@
+package webauth
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// Time an admin has to open a started impersonation.
+const impersonateTimeout = time.Minute
+
+// ImpersonateMax is the maximum duration of an impersonation session.
+const ImpersonateMax = 8 * time.Hour
+
+// Impersonations started by an admin, keyed by single-use token.
+var impersonations = struct {
+	sync.Mutex
+	m map[string]impersonation
+}{m: map[string]impersonation{}}
+
+type impersonation struct {
+	kind        string
+	accountName string
+	actor       string
+	readOnly    bool
+	duration    time.Duration
+	expires     time.Time // Of the token.
+}
+
+// ImpersonateStart prepares a session for an admin acting as an account in the
+// web interface of kind (e.g. "webmail"), returning a single-use token to open
+// "impersonate?token=..." with, handled by Impersonate. The token is valid for 1
+// minute. Actor is the admin, as for audit events. The session ends after duration.
+// If readOnly is set, the web interface must not allow making changes.
+func ImpersonateStart(kind, accountName, actor string, readOnly bool, duration time.Duration) (string, error) {
+	if duration <= 0 || duration > ImpersonateMax {
+		return "", fmt.Errorf("duration must be between 0 and %s", ImpersonateMax)
+	}
+	if _, err := impersonateLoginAddress(accountName); err != nil {
+		return "", err
+	}
+
+	token := oidcRandom()
+	impersonations.Lock()
+	defer impersonations.Unlock()
+	for k, imp := range impersonations.m {
+		if time.Until(imp.expires) < 0 {
+			delete(impersonations.m, k)
+		}
+	}
+	impersonations.m[token] = impersonation{kind, accountName, actor, readOnly, duration, time.Now().Add(impersonateTimeout)}
+	return token, nil
+}
+
+// impersonateLoginAddress returns the address to use as login address for the
+// session, the first non-catchall address of the account.
+func impersonateLoginAddress(accountName string) (string, error) {
+	accConf, ok := mox.Conf.Account(accountName)
+	if !ok {
+		return "", fmt.Errorf("%w: account %q", store.ErrAccountUnknown, accountName)
+	}
+	for _, a := range slices.Sorted(maps.Keys(accConf.Destinations)) {
+		if strings.HasPrefix(a, "@") {
+			continue
+		}
+		if addr, err := smtp.ParseAddress(a); err == nil {
+			return addr.String(), nil
+		}
+	}
+	return "", fmt.Errorf("account has no address to login with")
+}
+
+// Impersonate handles requests to "impersonate" with a token from
+// ImpersonateStart. The session is added and its cookie set, and the CSRF token is
+// passed to the frontend like after a login. The start of the session is recorded
+// in the audit log.
+func Impersonate(ctx context.Context, log mlog.Log, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ip := RemoteIP(log, isForwarded, r)
+	if ip == nil {
+		http.Error(w, "500 - internal server error - cannot find remote ip (missing x-forwarded-for header?)", http.StatusInternalServerError)
+		return
+	}
+
+	token := r.URL.Query().Get("token")
+	impersonations.Lock()
+	imp, ok := impersonations.m[token]
+	delete(impersonations.m, token)
+	impersonations.Unlock()
+	if !ok || imp.kind != kind || time.Until(imp.expires) < 0 {
+		time.Sleep(BadAuthDelay)
+		http.Error(w, "400 - bad request - unknown or expired impersonation token", http.StatusBadRequest)
+		return
+	}
+
+	event := store.AuditEvent{
+		Kind:     store.AuditImpersonate,
+		Account:  imp.accountName,
+		Actor:    imp.actor,
+		Protocol: kind,
+		RemoteIP: ip.String(),
+		Action:   "start",
+		Result:   "ok",
+	}
+	if imp.readOnly {
+		event.Details = "read-only, " + imp.duration.String()
+	} else {
+		event.Details = "full access, " + imp.duration.String()
+	}
+	defer func() {
+		store.AuditAdd(context.Background(), log, event)
+	}()
+
+	loginAddress, err := impersonateLoginAddress(imp.accountName)
+	if err != nil {
+		event.Result = err.Error()
+		http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	sessionToken, csrfToken, err := store.SessionAddImpersonation(ctx, log, imp.accountName, loginAddress, kind, ip.String(), r.UserAgent(), imp.actor, imp.readOnly, time.Now().Add(imp.duration))
+	if err != nil {
+		event.Result = err.Error()
+		log.Errorx("adding impersonation session", err)
+		http.Error(w, "500 - internal server error - adding session", http.StatusInternalServerError)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     kind + "session",
+		Value:    string(sessionToken) + " " + url.QueryEscape(imp.accountName),
+		Path:     cookiePath,
+		Secure:   isHTTPS(isForwarded, r),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	args := struct {
+		Kind      string
+		Address   string
+		CSRFToken store.CSRFToken
+		Continue  string
+	}{kind, "", csrfToken, "./"}
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	h.Set("Referrer-Policy", "no-referrer")
+	if err := loggedInTemplate.Execute(w, args); err != nil {
+		log.Check(err, "writing impersonation page")
+	}
+}
@@ -0,0 +1,241 @@
+package webauth
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/oidc"
+	"github.com/mjl-/mox/store"
+)
+
+// Time the user gets to login at the identity provider.
+const oidcTimeout = 10 * time.Minute
+
+// oidcSessionAuth is implemented by SessionAuth implementations that support
+// logging in through an OpenID Connect identity provider.
+type oidcSessionAuth interface {
+	// OIDCCheck verifies the account with email address can login. If disabled is
+	// true, the error must be non-nil and contain details.
+	oidcCheck(ctx context.Context, log mlog.Log, kind, address string) (valid, disabled bool, accountName string, rerr error)
+}
+
+// Pending logins at the identity provider, keyed by state. The state is also
+// stored in a cookie, binding the login to the browser that started it.
+var oidcLogins = struct {
+	sync.Mutex
+	m map[string]oidcLogin
+}{m: map[string]oidcLogin{}}
+
+type oidcLogin struct {
+	kind     string
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+func oidcRandom() string {
+	buf := make([]byte, 24)
+	cryptorand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func oidcRedirectURI(cookiePath string, isForwarded bool, r *http.Request) string {
+	_, origin := RequestOrigin(isForwarded, r)
+	return origin + strings.TrimRight(cookiePath, "/") + "/oidc/callback"
+}
+
+func setOIDCCookie(kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     kind + "oidc",
+		Value:    state,
+		Path:     cookiePath,
+		Secure:   isHTTPS(isForwarded, r),
+		HttpOnly: true,
+		// The identity provider sends the browser back with a cross-site navigation.
+		SameSite: http.SameSiteLaxMode,
+		MaxAge:   maxAge,
+	})
+}
+
+// OIDCLogin starts a web login through the OpenID Connect identity provider, for
+// requests to "oidc/login", by redirecting the browser to the provider. After
+// logging in, the provider sends the browser back to "oidc/callback", handled by
+// OIDCCallback.
+func OIDCLogin(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	if _, ok := sessionAuth.(oidcSessionAuth); !ok || !oidc.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
+		return
+	}
+
+	state := oidcRandom()
+	login := oidcLogin{kind, oidcRandom(), oidcRandom(), time.Now().Add(oidcTimeout)}
+	u, err := oidc.AuthCodeURL(ctx, log, oidcRedirectURI(cookiePath, isForwarded, r), state, login.nonce, login.verifier)
+	if err != nil {
+		log.Errorx("preparing login at identity provider", err)
+		http.Error(w, "502 - bad gateway - cannot reach identity provider", http.StatusBadGateway)
+		return
+	}
+
+	oidcLogins.Lock()
+	for k, l := range oidcLogins.m {
+		if time.Until(l.expires) < 0 {
+			delete(oidcLogins.m, k)
+		}
+	}
+	oidcLogins.m[state] = login
+	oidcLogins.Unlock()
+
+	setOIDCCookie(kind, cookiePath, isForwarded, w, r, state, int(oidcTimeout/time.Second))
+	h := w.Header()
+	h.Set("Cache-Control", "no-store")
+	http.Redirect(w, r, u, http.StatusSeeOther)
+}
+
+// The page after a successful login stores the CSRF token for the frontend, like
+// the login form does, and loads the web interface at relative URL Continue. Also
+// used for impersonation.
+var loggedInTemplate = template.Must(template.New("loggedin").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<title>Logged in</title>
+	</head>
+	<body>
+		<p>Logged in, <a href="{{ .Continue }}">continue</a>.</p>
+		<script>
+try {
+	{{ if .Address }}window.localStorage.setItem({{ .Kind }} + 'address', {{ .Address }})
+	{{ end }}window.localStorage.setItem({{ .Kind }} + 'csrftoken', {{ .CSRFToken }})
+} catch (err) {
+	console.log('saving csrf token in localStorage', err)
+}
+window.location.replace({{ .Continue }})
+		</script>
+	</body>
+</html>
+`))
+
+// OIDCCallback handles the browser returning from the identity provider, for
+// requests to "oidc/callback". After verifying the login, the session cookie is
+// set, and the CSRF token passed to the frontend.
+func OIDCCallback(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request) {
+	oa, ok := sessionAuth.(oidcSessionAuth)
+	if !ok || !oidc.Enabled() {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != "GET" {
+		http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ip := RemoteIP(log, isForwarded, r)
+	if ip == nil {
+		http.Error(w, "500 - internal server error - cannot find ip for rate limit check (missing x-forwarded-for header?)", http.StatusInternalServerError)
+		return
+	}
+	start := time.Now()
+	if !mox.LimiterFailedAuth.Add(ip, start, 1) {
+		metrics.AuthenticationRatelimitedInc(kind)
+		http.Error(w, "429 - too many auth attempts", http.StatusTooManyRequests)
+		return
+	}
+
+	q := r.URL.Query()
+	state := q.Get("state")
+	stateCookie, _ := r.Cookie(kind + "oidc")
+	if stateCookie == nil || state == "" || stateCookie.Value != state {
+		http.Error(w, "400 - bad request - missing or mismatched login state cookie", http.StatusBadRequest)
+		return
+	}
+	setOIDCCookie(kind, cookiePath, isForwarded, w, r, "", -1)
+
+	oidcLogins.Lock()
+	login, ok := oidcLogins.m[state]
+	delete(oidcLogins.m, state)
+	oidcLogins.Unlock()
+	if !ok || login.kind != kind || time.Until(login.expires) < 0 {
+		http.Error(w, "400 - bad request - no login in progress, or timed out", http.StatusBadRequest)
+		return
+	}
+	if e := q.Get("error"); e != "" {
+		// E.g. "access_denied" when the user cancelled, RFC 6749 section 4.1.2.1.
+		http.Error(w, "403 - forbidden - login at identity provider failed: "+e+" "+q.Get("error_description"), http.StatusForbidden)
+		return
+	}
+
+	la := loginAttempt(ip.String(), r, kind, "oidc")
+	defer func() {
+		store.LoginAttemptAdd(context.Background(), log, la)
+	}()
+
+	address, err := oidc.Exchange(ctx, log, oidcRedirectURI(cookiePath, isForwarded, r), q.Get("code"), login.verifier, login.nonce)
+	if err != nil {
+		log.Infox("verifying login at identity provider", err)
+		la.Result = store.AuthBadCredentials
+		http.Error(w, "403 - forbidden - verifying login at identity provider: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	la.LoginAddress = address
+
+	valid, disabled, accountName, err := oa.oidcCheck(ctx, log, kind, address)
+	la.AccountName = accountName
+	if disabled {
+		la.Result = store.AuthLoginDisabled
+		if errors.Is(err, store.ErrLoginLocked) {
+			la.Result = store.AuthLoginLocked
+		}
+		http.Error(w, "403 - forbidden - "+err.Error(), http.StatusForbidden)
+		return
+	} else if err != nil {
+		la.Result = store.AuthError
+		log.Errorx("evaluating login attempt", err)
+		http.Error(w, "500 - internal server error - evaluating login attempt", http.StatusInternalServerError)
+		return
+	} else if !valid {
+		time.Sleep(BadAuthDelay)
+		la.Result = store.AuthBadCredentials
+		http.Error(w, "403 - forbidden - no account for address "+address, http.StatusForbidden)
+		return
+	}
+	la.Result = store.AuthSuccess
+	mox.LimiterFailedAuth.Reset(ip, start)
+
+	csrfToken, err := loginSession(ctx, log, sessionAuth, kind, cookiePath, isForwarded, w, r, ip, accountName, address)
+	if err != nil {
+		la.Result = store.AuthError
+		http.Error(w, "500 - internal server error - "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	args := struct {
+		Kind      string
+		Address   string
+		CSRFToken store.CSRFToken
+		Continue  string
+	}{kind, "", csrfToken, "../"}
+	if kind == "webaccount" {
+		// Shown in the account interface.
+		args.Address = address
+	}
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	if err := loggedInTemplate.Execute(w, args); err != nil {
+		log.Check(err, "writing logged in page")
+	}
+}
@@ -0,0 +1,312 @@
+package webauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/mjl-/bstore"
+	"github.com/mjl-/sherpa"
+
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webauthn"
+)
+
+// Time the browser gets for a passkey ceremony, in which the user selects a
+// passkey and unlocks it.
+const passkeyTimeout = 2 * time.Minute
+
+// passkeySessionAuth is implemented by SessionAuth implementations that support
+// logging in with a passkey.
+type passkeySessionAuth interface {
+	// PasskeyCheck verifies the owner of a passkey can login, for a passkey login
+	// without password. PasskeyAccount is the account stored with the passkey, empty
+	// for admin passkeys. Valid is false if the passkey is not for this kind of
+	// login. If disabled is true, the error must be non-nil and contain details.
+	passkeyCheck(ctx context.Context, log mlog.Log, kind, passkeyAccount string) (valid, disabled bool, accountName string, rerr error)
+}
+
+// Challenges for pending passkey ceremonies, keyed by kind and login token for
+// logins, and kind and account for registrations. A challenge can only be used
+// once.
+var passkeyChallenges = struct {
+	sync.Mutex
+	m map[string]passkeyChallenge
+}{m: map[string]passkeyChallenge{}}
+
+type passkeyChallenge struct {
+	challenge []byte
+	expires   time.Time
+}
+
+func passkeyChallengeNew(key string) []byte {
+	passkeyChallenges.Lock()
+	defer passkeyChallenges.Unlock()
+	for k, c := range passkeyChallenges.m {
+		if time.Until(c.expires) < 0 {
+			delete(passkeyChallenges.m, k)
+		}
+	}
+	c := webauthn.NewChallenge()
+	passkeyChallenges.m[key] = passkeyChallenge{c, time.Now().Add(passkeyTimeout + time.Minute)}
+	return c
+}
+
+// passkeyChallengeTake returns the challenge for key, removing it. Nil is
+// returned if there is no pending challenge.
+func passkeyChallengeTake(key string) []byte {
+	passkeyChallenges.Lock()
+	defer passkeyChallenges.Unlock()
+	c, ok := passkeyChallenges.m[key]
+	delete(passkeyChallenges.m, key)
+	if !ok || time.Until(c.expires) < 0 {
+		return nil
+	}
+	return c.challenge
+}
+
+// passkeyRP returns the relying party ID, the hostname the web interface is
+// accessed at, and the origin the browser will report.
+func passkeyRP(isForwarded bool, r *http.Request) (rpID, origin string) {
+	host, origin := RequestOrigin(isForwarded, r)
+	rpID = host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		rpID = h
+	}
+	return rpID, origin
+}
+
+// PasskeyGetOptions are the parameters for navigator.credentials.get in the
+// browser for a passkey login. No credentials are specified: the browser lets
+// the user choose from the passkeys registered for the site.
+type PasskeyGetOptions struct {
+	Challenge []byte
+	RPID      string
+	Timeout   int // In milliseconds.
+}
+
+// PasskeyAssertion is the response from navigator.credentials.get.
+type PasskeyAssertion struct {
+	CredentialID      []byte
+	ClientDataJSON    []byte
+	AuthenticatorData []byte
+	Signature         []byte
+}
+
+// PasskeyLoginStart prepares a passkey login for a loginToken from LoginPrep,
+// returning the options for the browser. The login cookie is extended for the
+// duration of the ceremony.
+func PasskeyLoginStart(ctx context.Context, log mlog.Log, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, loginToken string) (PasskeyGetOptions, error) {
+	if err := checkLoginToken(kind, isForwarded, r, loginToken); err != nil {
+		return PasskeyGetOptions{}, err
+	}
+	setLoginCookie(kind, cookiePath, isForwarded, w, r, loginToken, int((passkeyTimeout + time.Minute).Seconds()))
+	challenge := passkeyChallengeNew("login " + kind + " " + loginToken)
+	rpID, _ := passkeyRP(isForwarded, r)
+	return PasskeyGetOptions{challenge, rpID, int(passkeyTimeout / time.Millisecond)}, nil
+}
+
+// PasskeyLogin handles a login attempt with a passkey, started with
+// PasskeyLoginStart. Like Login, it checks against the rate limiter, sets a
+// session cookie and returns a CSRF token.
+//
+// If password is empty, the passkey is the only factor, and the authenticator
+// must have verified the user, e.g. with a PIN or biometrics. Otherwise, the
+// passkey is the second factor after the password for username, and must belong
+// to the same account.
+//
+// For a bad passkey or password, the error code is "user:loginFailed".
+func PasskeyLogin(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, loginToken, username, password string, assertion PasskeyAssertion) (store.CSRFToken, error) {
+	if err := checkLoginToken(kind, isForwarded, r, loginToken); err != nil {
+		return "", err
+	}
+	pa, ok := sessionAuth.(passkeySessionAuth)
+	if !ok {
+		return "", &sherpa.Error{Code: "user:error", Message: "passkey login not supported"}
+	}
+
+	ip := RemoteIP(log, isForwarded, r)
+	if ip == nil {
+		return "", fmt.Errorf("cannot find ip for rate limit check (missing x-forwarded-for header?)")
+	}
+	start := time.Now()
+	if !mox.LimiterFailedAuth.Add(ip, start, 1) {
+		metrics.AuthenticationRatelimitedInc(kind)
+		return "", &sherpa.Error{Code: "user:error", Message: "too many authentication attempts"}
+	}
+
+	challenge := passkeyChallengeTake("login " + kind + " " + loginToken)
+	if challenge == nil {
+		return "", &sherpa.Error{Code: "user:error", Message: "no passkey login in progress, or timed out"}
+	}
+
+	username = norm.NFC.String(username)
+	la := loginAttempt(ip.String(), r, kind, "passkey")
+	la.LoginAddress = username
+	defer func() {
+		store.LoginAttemptAdd(context.Background(), log, la)
+	}()
+
+	badCredentials := func(msg string) (store.CSRFToken, error) {
+		time.Sleep(BadAuthDelay)
+		la.Result = store.AuthBadCredentials
+		return "", &sherpa.Error{Code: "user:loginFailed", Message: msg}
+	}
+
+	pk, err := store.PasskeyGet(ctx, store.PasskeyCredentialID(assertion.CredentialID))
+	if err == bstore.ErrAbsent {
+		return badCredentials("unknown passkey")
+	} else if err != nil {
+		la.Result = store.AuthError
+		return "", fmt.Errorf("looking up passkey: %v", err)
+	}
+
+	valid, disabled, accountName, err := pa.passkeyCheck(ctx, log, kind, pk.Account)
+	la.AccountName = accountName
+	if la.LoginAddress == "" {
+		la.LoginAddress = pk.LoginAddress
+	}
+	if disabled {
+		la.Result = store.AuthLoginDisabled
+		if errors.Is(err, store.ErrLoginLocked) {
+			la.Result = store.AuthLoginLocked
+		}
+		return "", &sherpa.Error{Code: "user:loginFailed", Message: err.Error()}
+	} else if err != nil {
+		la.Result = store.AuthError
+		return "", fmt.Errorf("evaluating passkey login attempt: %v", err)
+	} else if !valid {
+		return badCredentials("passkey not valid for this login")
+	}
+
+	rpID, origin := passkeyRP(isForwarded, r)
+	signCount, err := webauthn.VerifyAssertion(rpID, origin, challenge, pk.PublicKey, pk.SignCount, assertion.ClientDataJSON, assertion.AuthenticatorData, assertion.Signature, password == "")
+	if err != nil {
+		log.Debugx("verifying passkey assertion", err, slog.String("credentialid", pk.CredentialID))
+		if errors.Is(err, webauthn.ErrUserVerification) {
+			return badCredentials("passkey did not verify user, login with password and passkey, or use a passkey that verifies the user, e.g. with a pin")
+		}
+		return badCredentials("invalid passkey response")
+	}
+
+	loginAddress := pk.LoginAddress
+	if password != "" {
+		pvalid, pdisabled, paccountName, err := sessionAuth.login(ctx, log, kind, username, password)
+		if pdisabled {
+			la.Result = store.AuthLoginDisabled
+			if errors.Is(err, store.ErrLoginLocked) {
+				la.Result = store.AuthLoginLocked
+			} else if errors.Is(err, store.ErrPasswordExpired) {
+				la.Result = store.AuthPasswordExpired
+			}
+			return "", &sherpa.Error{Code: "user:loginFailed", Message: err.Error()}
+		} else if err != nil {
+			la.Result = store.AuthError
+			return "", fmt.Errorf("evaluating login attempt: %v", err)
+		} else if !pvalid || paccountName != accountName {
+			return badCredentials("invalid credentials")
+		}
+		loginAddress = username
+	}
+
+	if err := store.PasskeyUsed(ctx, pk.CredentialID, signCount); err != nil {
+		la.Result = store.AuthError
+		return "", fmt.Errorf("updating passkey: %v", err)
+	}
+
+	la.Result = store.AuthSuccess
+	mox.LimiterFailedAuth.Reset(ip, start)
+
+	csrfToken, err := loginSession(ctx, log, sessionAuth, kind, cookiePath, isForwarded, w, r, ip, accountName, loginAddress)
+	if err != nil {
+		la.Result = store.AuthError
+	}
+	return csrfToken, err
+}
+
+// PasskeyCreateOptions are the parameters for navigator.credentials.create in
+// the browser, for registering a new passkey.
+type PasskeyCreateOptions struct {
+	Challenge          []byte
+	RPID               string
+	RPName             string
+	UserID             []byte
+	UserName           string
+	Algorithms         []int    // COSE algorithm identifiers.
+	ExcludeCredentials [][]byte // Already registered, the browser won't register a second passkey on the same authenticator.
+	Timeout            int      // In milliseconds.
+}
+
+// PasskeyRegisterStart prepares registration of a new passkey for account, or
+// for the admin if account is empty. UserName is shown by the browser and
+// password managers. Kind is the web interface the passkey is registered in.
+func PasskeyRegisterStart(ctx context.Context, kind string, isForwarded bool, r *http.Request, account, userName string) (PasskeyCreateOptions, error) {
+	pks, err := store.PasskeyList(ctx, account)
+	if err != nil {
+		return PasskeyCreateOptions{}, fmt.Errorf("listing passkeys: %v", err)
+	}
+	var exclude [][]byte
+	for _, pk := range pks {
+		id, err := base64.RawURLEncoding.DecodeString(pk.CredentialID)
+		if err == nil {
+			exclude = append(exclude, id)
+		}
+	}
+
+	rpID, _ := passkeyRP(isForwarded, r)
+	// The user ID only needs to be stable per account, we don't look up by it.
+	uid := sha256.Sum256([]byte("mox passkey user " + account))
+	return PasskeyCreateOptions{
+		Challenge:          passkeyChallengeNew("register " + kind + " " + account),
+		RPID:               rpID,
+		RPName:             mox.Conf.Static.HostnameDomain.ASCII,
+		UserID:             uid[:16],
+		UserName:           userName,
+		Algorithms:         webauthn.Algorithms,
+		ExcludeCredentials: exclude,
+		Timeout:            int(passkeyTimeout / time.Millisecond),
+	}, nil
+}
+
+// PasskeyRegister verifies the response of navigator.credentials.create for a
+// registration started with PasskeyRegisterStart, and stores the new passkey.
+// LoginAddress is used for sessions after logging in with the passkey.
+func PasskeyRegister(ctx context.Context, log mlog.Log, kind string, isForwarded bool, r *http.Request, account, loginAddress, name string, clientDataJSON, attestationObject []byte) (store.Passkey, error) {
+	if name == "" {
+		return store.Passkey{}, fmt.Errorf("name required")
+	}
+	challenge := passkeyChallengeTake("register " + kind + " " + account)
+	if challenge == nil {
+		return store.Passkey{}, fmt.Errorf("no passkey registration in progress, or timed out")
+	}
+	rpID, origin := passkeyRP(isForwarded, r)
+	cred, err := webauthn.ParseCreation(rpID, origin, challenge, clientDataJSON, attestationObject)
+	if err != nil {
+		return store.Passkey{}, err
+	}
+	pk := store.Passkey{
+		CredentialID: store.PasskeyCredentialID(cred.ID),
+		Name:         name,
+		Account:      account,
+		LoginAddress: loginAddress,
+		PublicKey:    cred.PublicKey,
+		SignCount:    cred.SignCount,
+	}
+	if err := store.PasskeyAdd(ctx, &pk); err != nil {
+		return store.Passkey{}, err
+	}
+	log.Info("passkey registered", slog.String("account", account), slog.String("name", name))
+	return pk, nil
+}
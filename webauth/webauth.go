@@ -31,12 +31,28 @@ Sessions for the account and mail interfaces have a lifetime of 24 hours after
 last use, are kept in memory and stored in the database (do survive a server
 restart), and only 100 sessions can exist per account (the oldest session is
 dropped).
+
+If two-factor authentication is enabled, a login also requires a time-based
+one-time password (TOTP) from an authenticator app.
+
+If an OpenID Connect identity provider is configured, users of the account and
+mail interfaces can also login through the provider, starting at "oidc/login".
+The provider sends the browser back to "oidc/callback", where a session is
+created for the account with the email address from the provider.
+
+Admins can act as an account in the mail interface, for troubleshooting. The
+admin interface gets a single-use token, opened at "impersonate" in the mail
+interface, which creates a session that ends after a fixed duration, optionally
+read-only. The start of the session and API calls made through it are recorded
+in the audit log.
 */
 package webauth
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net"
@@ -46,6 +62,7 @@ import (
 	"time"
 
 	"golang.org/x/text/unicode/norm"
+	"rsc.io/qr"
 
 	"github.com/mjl-/sherpa"
 
@@ -53,20 +70,43 @@ import (
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/totp"
 )
 
 // Delay before responding in case of bad authentication attempt.
 var BadAuthDelay = time.Second
 
+// recoverySessionAuth is implemented by a SessionAuth that allows a one-time
+// recovery code as second factor instead of a two-factor authentication code.
+type recoverySessionAuth interface {
+	// Recover verifies the recovery code after the password was verified, consuming
+	// it if valid.
+	recover(ctx context.Context, log mlog.Log, accountName, code string) (valid bool, rerr error)
+}
+
+// twoFactorSessionAuth is implemented by SessionAuth implementations that support
+// two-factor authentication.
+type twoFactorSessionAuth interface {
+	// TwoFactor verifies the second factor after the password was verified. Nil is
+	// returned if code is valid or no second factor is needed. If a code is needed
+	// but empty, store.ErrTOTPRequired is returned, and store.ErrUnknownCredentials
+	// for a bad code. For accounts that must but haven't set up two-factor
+	// authentication, store.ErrTwoFactorSetup is returned, except when logging into
+	// the account web interface, where it can be set up.
+	twoFactor(ctx context.Context, log mlog.Log, kind, accountName, code string) error
+}
+
 // SessionAuth handles login and session storage, used for both account and
 // admin authentication.
 type SessionAuth interface {
 	// Login verifies the password. Valid indicates the attempt was successful. If
-	// disabled is true, the error must be non-nil and contain details.
-	login(ctx context.Context, log mlog.Log, username, password string) (valid bool, disabled bool, accountName string, rerr error)
+	// disabled is true, the error must be non-nil and contain details. Kind is the web
+	// interface being logged into.
+	login(ctx context.Context, log mlog.Log, kind, username, password string) (valid bool, disabled bool, accountName string, rerr error)
 
-	// Add a new session for account and login address.
-	add(ctx context.Context, log mlog.Log, accountName string, loginAddress string) (sessionToken store.SessionToken, csrfToken store.CSRFToken, rerr error)
+	// Add a new session for account and login address. Kind is the web interface
+	// logged into, remoteIP and userAgent are of the login request.
+	add(ctx context.Context, log mlog.Log, accountName, loginAddress, kind, remoteIP, userAgent string) (sessionToken store.SessionToken, csrfToken store.CSRFToken, rerr error)
 
 	// Use an existing session. If csrfToken is empty, no CSRF check must be done.
 	// Otherwise the CSRF token must be associated with the session token, as returned
@@ -219,6 +259,30 @@ func isHTTPS(isForwarded bool, r *http.Request) bool {
 	return r.TLS != nil
 }
 
+// RequestOrigin returns the host, possibly with port, and the origin of the web
+// interface, as used by the browser.
+func RequestOrigin(isForwarded bool, r *http.Request) (host, origin string) {
+	host = r.Host
+	if isForwarded && r.Header.Get("X-Forwarded-Host") != "" {
+		host = r.Header.Get("X-Forwarded-Host")
+	}
+	scheme := "http"
+	if isHTTPS(isForwarded, r) {
+		scheme = "https"
+	}
+	return host, scheme + "://" + host
+}
+
+// IsLoginPath returns whether path is an API call for logging in, which the web
+// interfaces allow without session.
+func IsLoginPath(path string) bool {
+	switch path {
+	case "/api/LoginPrep", "/api/Login", "/api/PasskeyLoginStart", "/api/PasskeyLogin", "/api/OIDCEnabled":
+		return true
+	}
+	return false
+}
+
 // LoginPrep is an API call that returns a loginToken and also sets it as cookie
 // with the same value. The loginToken must be passed to a subsequent call to
 // Login, which will check that the loginToken and cookie are both present and
@@ -227,6 +291,10 @@ func isHTTPS(isForwarded bool, r *http.Request) bool {
 func LoginPrep(ctx context.Context, log mlog.Log, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, token string) {
 	// todo future: we could sign the login token, and verify it on use, so subdomains cannot set it to known values.
 
+	setLoginCookie(kind, cookiePath, isForwarded, w, r, token, 30) // Only for one login attempt.
+}
+
+func setLoginCookie(kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, token string, maxAge int) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     kind + "login",
 		Value:    token,
@@ -234,24 +302,36 @@ func LoginPrep(ctx context.Context, log mlog.Log, kind, cookiePath string, isFor
 		Secure:   isHTTPS(isForwarded, r),
 		HttpOnly: true,
 		SameSite: http.SameSiteStrictMode,
-		MaxAge:   30, // Only for one login attempt.
+		MaxAge:   maxAge,
 	})
 }
 
-// Login handles a login attempt, checking against the rate limiter, verifying the
-// credentials through sessionAuth, and setting a session token cookie on the HTTP
-// response and returning the associated CSRF token.
-//
-// In case of a user error, a *sherpa.Error is returned that sherpa handlers can
-// pass to panic. For bad credentials, the error code is "user:loginFailed".
-func Login(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, loginToken, username, password string) (store.CSRFToken, error) {
+// checkLoginToken checks the login token matches the cookie set by LoginPrep.
+func checkLoginToken(kind string, isForwarded bool, r *http.Request, loginToken string) error {
 	loginCookie, _ := r.Cookie(kind + "login")
 	if loginCookie == nil || loginCookie.Value != loginToken {
 		msg := "missing login token cookie"
 		if isForwarded && loginCookie == nil {
 			msg += " (hint: reverse proxy must keep path, for login cookie)"
 		}
-		return "", &sherpa.Error{Code: "user:error", Message: msg}
+		return &sherpa.Error{Code: "user:error", Message: msg}
+	}
+	return nil
+}
+
+// Login handles a login attempt, checking against the rate limiter, verifying the
+// credentials through sessionAuth, and setting a session token cookie on the HTTP
+// response and returning the associated CSRF token.
+//
+// Code is a one-time password for two-factor authentication, if enabled.
+//
+// In case of a user error, a *sherpa.Error is returned that sherpa handlers can
+// pass to panic. For bad credentials, the error code is "user:loginFailed". If the
+// password is valid but a two-factor authentication code is needed, the error code
+// is "user:totpRequired".
+func Login(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, loginToken, username, password, code string) (store.CSRFToken, error) {
+	if err := checkLoginToken(kind, isForwarded, r, loginToken); err != nil {
+		return "", err
 	}
 
 	ip := RemoteIP(log, isForwarded, r)
@@ -265,7 +345,7 @@ func Login(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, coo
 	}
 
 	username = norm.NFC.String(username)
-	valid, disabled, accountName, err := sessionAuth.login(ctx, log, username, password)
+	valid, disabled, accountName, err := sessionAuth.login(ctx, log, kind, username, password)
 	la := loginAttempt(ip.String(), r, kind, "weblogin")
 	la.LoginAddress = username
 	la.AccountName = accountName
@@ -274,6 +354,11 @@ func Login(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, coo
 	}()
 	if disabled {
 		la.Result = store.AuthLoginDisabled
+		if errors.Is(err, store.ErrLoginLocked) {
+			la.Result = store.AuthLoginLocked
+		} else if errors.Is(err, store.ErrPasswordExpired) {
+			la.Result = store.AuthPasswordExpired
+		}
 		return "", &sherpa.Error{Code: "user:loginFailed", Message: err.Error()}
 	} else if err != nil {
 		la.Result = store.AuthError
@@ -283,12 +368,48 @@ func Login(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, coo
 		la.Result = store.AuthBadCredentials
 		return "", &sherpa.Error{Code: "user:loginFailed", Message: "invalid credentials"}
 	}
+	if tfa, ok := sessionAuth.(twoFactorSessionAuth); ok {
+		err := tfa.twoFactor(ctx, log, kind, accountName, code)
+		// A recovery code can be used instead of a lost second factor. Failed attempts
+		// count as bad credentials, like bad two-factor codes.
+		if ra, ok := sessionAuth.(recoverySessionAuth); ok && errors.Is(err, store.ErrUnknownCredentials) {
+			if rvalid, rerr := ra.recover(ctx, log, accountName, code); rerr != nil {
+				err = rerr
+			} else if rvalid {
+				err = nil
+				la.AuthMech = "recoverycode"
+			}
+		}
+		if errors.Is(err, store.ErrTOTPRequired) {
+			la.Result = store.AuthTOTPRequired
+			return "", &sherpa.Error{Code: "user:totpRequired", Message: err.Error()}
+		} else if errors.Is(err, store.ErrUnknownCredentials) {
+			time.Sleep(BadAuthDelay)
+			la.Result = store.AuthBadCredentials
+			return "", &sherpa.Error{Code: "user:loginFailed", Message: "invalid two-factor authentication code"}
+		} else if errors.Is(err, store.ErrTwoFactorSetup) {
+			la.Result = store.AuthTwoFactorSetup
+			return "", &sherpa.Error{Code: "user:loginFailed", Message: err.Error()}
+		} else if err != nil {
+			la.Result = store.AuthError
+			return "", fmt.Errorf("verifying two-factor authentication: %v", err)
+		}
+	}
 	la.Result = store.AuthSuccess
 	mox.LimiterFailedAuth.Reset(ip, start)
 
-	sessionToken, csrfToken, err := sessionAuth.add(ctx, log, accountName, username)
+	csrfToken, err := loginSession(ctx, log, sessionAuth, kind, cookiePath, isForwarded, w, r, ip, accountName, username)
 	if err != nil {
 		la.Result = store.AuthError
+	}
+	return csrfToken, err
+}
+
+// loginSession adds a session after a successful login, and sets the session
+// cookie.
+func loginSession(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, cookiePath string, isForwarded bool, w http.ResponseWriter, r *http.Request, ip net.IP, accountName, loginAddress string) (store.CSRFToken, error) {
+	sessionToken, csrfToken, err := sessionAuth.add(ctx, log, accountName, loginAddress, kind, ip.String(), r.UserAgent())
+	if err != nil {
 		log.Errorx("adding session after login", err)
 		return "", fmt.Errorf("adding session: %v", err)
 	}
@@ -308,14 +429,7 @@ func Login(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, coo
 		// the browser remembering the password.
 	})
 	// Remove cookie used during login.
-	http.SetCookie(w, &http.Cookie{
-		Name:     kind + "login",
-		Path:     cookiePath,
-		Secure:   isHTTPS(isForwarded, r),
-		HttpOnly: true,
-		SameSite: http.SameSiteStrictMode,
-		MaxAge:   -1, // Delete cookie
-	})
+	setLoginCookie(kind, cookiePath, isForwarded, w, r, "", -1)
 	return csrfToken, nil
 }
 
@@ -337,3 +451,22 @@ func Logout(ctx context.Context, log mlog.Log, sessionAuth SessionAuth, kind, co
 	})
 	return nil
 }
+
+// TOTPSetup has the parameters for configuring an authenticator app.
+type TOTPSetup struct {
+	Secret    string // Base32, for manual entry.
+	URL       string // otpauth URL, as in the QR code.
+	QRCodePNG string // Data URL with PNG image of QR code.
+}
+
+// NewTOTPSetup returns the parameters for setting up an authenticator app with
+// secret for login name.
+func NewTOTPSetup(name string, secret []byte) (TOTPSetup, error) {
+	u := totp.URL(mox.Conf.Static.HostnameDomain.ASCII, name, secret)
+	code, err := qr.Encode(u, qr.M)
+	if err != nil {
+		return TOTPSetup{}, fmt.Errorf("generating qr code: %v", err)
+	}
+	png := "data:image/png;base64," + base64.StdEncoding.EncodeToString(code.PNG())
+	return TOTPSetup{totp.EncodeSecret(secret), u, png}, nil
+}
@@ -0,0 +1,102 @@
+package webauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjl-/sherpa"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/totp"
+)
+
+var ctxbg = context.Background()
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+// TestLoginRecoveryCode checks recovery codes can only be used instead of a
+// two-factor authentication code, not instead of the password or to get around
+// a lockout.
+func TestLoginRecoveryCode(t *testing.T) {
+	log := mlog.New("webauth", nil)
+	os.RemoveAll("../testdata/webauth/data")
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/webauth/mox.conf")
+	mox.ConfigDynamicPath = filepath.Join(filepath.Dir(mox.ConfigStaticPath), "domains.conf")
+	mox.MustLoadConfig(true, false)
+	mox.LimitersInit()
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	BadAuthDelay = 0
+
+	acc, err := store.OpenAccount(log, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err = acc.Close()
+		tcheck(t, err, "closing account")
+		acc.WaitClosed()
+	}()
+	err = acc.SetPassword(log, "test1234")
+	tcheck(t, err, "set password")
+	secret, err := acc.TOTPSetupStart(log)
+	tcheck(t, err, "totp setup start")
+	err = acc.TOTPSetupConfirm(log, totp.Code(secret, totp.Step(time.Now())))
+	tcheck(t, err, "totp setup confirm")
+	codes, err := acc.RecoveryCodesGenerate(log)
+	tcheck(t, err, "generate recovery codes")
+
+	login := func(password, code string) error {
+		t.Helper()
+		r := httptest.NewRequest("POST", "/account/", nil)
+		r.AddCookie(&http.Cookie{Name: "webaccountlogin", Value: "logintoken"})
+		_, err := Login(ctxbg, log, Accounts, "webaccount", "/account/", false, httptest.NewRecorder(), r, "logintoken", "mjl@mox.example", password, code)
+		return err
+	}
+	needCode := func(err error, code string) {
+		t.Helper()
+		var serr *sherpa.Error
+		if !errors.As(err, &serr) || serr.Code != code {
+			t.Fatalf("got err %v, expected sherpa error with code %q", err, code)
+		}
+	}
+
+	// Not instead of the password, also not when passing it as second factor.
+	needCode(login(codes[0], ""), "user:loginFailed")
+	needCode(login("bogus", codes[0]), "user:loginFailed")
+
+	// Only instead of the two-factor authentication code, and only once.
+	needCode(login("test1234", ""), "user:totpRequired")
+	tcheck(t, login("test1234", codes[0]), "login with recovery code as second factor")
+	needCode(login("test1234", codes[0]), "user:loginFailed")
+
+	// Not to get around a lockout.
+	for range 3 {
+		needCode(login("bogus", ""), "user:loginFailed")
+	}
+	if err := store.CheckLoginLockout("mjl"); !errors.Is(err, store.ErrLoginLocked) {
+		t.Fatalf("got err %v, expected ErrLoginLocked", err)
+	}
+	needCode(login("test1234", codes[1]), "user:loginFailed")
+	needCode(login(codes[1], ""), "user:loginFailed")
+	if err := store.CheckLoginLockout("mjl"); !errors.Is(err, store.ErrLoginLocked) {
+		t.Fatalf("got err %v, expected account to stay locked", err)
+	}
+}
@@ -0,0 +1,130 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+var errCBORTruncated = errors.New("cbor: truncated data")
+
+// cborDecode decodes a single CBOR data item from buf, RFC 8949, returning the
+// value and the remaining bytes.
+//
+// Only the subset used in WebAuthn attestation objects and COSE keys is
+// implemented: integers (as int64), byte strings ([]byte), text strings
+// (string), arrays ([]any), maps (map[any]any with int64 or string keys), and the
+// simple values false, true and null. Indefinite lengths, tags and floats are
+// rejected.
+func cborDecode(buf []byte, depth int) (any, []byte, error) {
+	if depth > 16 {
+		return nil, nil, fmt.Errorf("cbor: nesting too deep")
+	}
+	if len(buf) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+	major := buf[0] >> 5
+	info := buf[0] & 0x1f
+	buf = buf[1:]
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, buf, nil
+		case 21:
+			return true, buf, nil
+		case 22:
+			return nil, buf, nil
+		}
+		return nil, nil, fmt.Errorf("cbor: unsupported simple value or float %d", info)
+	}
+
+	// Argument, the value for integers, the length for strings, arrays and maps.
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info == 24 && len(buf) >= 1:
+		arg = uint64(buf[0])
+		buf = buf[1:]
+	case info == 25 && len(buf) >= 2:
+		arg = uint64(binary.BigEndian.Uint16(buf))
+		buf = buf[2:]
+	case info == 26 && len(buf) >= 4:
+		arg = uint64(binary.BigEndian.Uint32(buf))
+		buf = buf[4:]
+	case info == 27 && len(buf) >= 8:
+		arg = binary.BigEndian.Uint64(buf)
+		buf = buf[8:]
+	case info >= 24 && info <= 27:
+		return nil, nil, errCBORTruncated
+	default:
+		return nil, nil, fmt.Errorf("cbor: unsupported additional information %d", info)
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cbor: integer too large")
+		}
+		return int64(arg), buf, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("cbor: integer too small")
+		}
+		return -1 - int64(arg), buf, nil
+	case 2, 3:
+		if arg > uint64(len(buf)) {
+			return nil, nil, errCBORTruncated
+		}
+		if major == 2 {
+			return buf[:arg], buf[arg:], nil
+		}
+		return string(buf[:arg]), buf[arg:], nil
+	case 4:
+		// Each element is at least one byte, don't allocate for bogus lengths.
+		if arg > uint64(len(buf)) {
+			return nil, nil, errCBORTruncated
+		}
+		l := make([]any, 0, arg)
+		for range arg {
+			var v any
+			var err error
+			v, buf, err = cborDecode(buf, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			l = append(l, v)
+		}
+		return l, buf, nil
+	case 5:
+		if arg > uint64(len(buf))/2 {
+			return nil, nil, errCBORTruncated
+		}
+		m := map[any]any{}
+		for range arg {
+			var k, v any
+			var err error
+			k, buf, err = cborDecode(buf, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch k.(type) {
+			case int64, string:
+			default:
+				return nil, nil, fmt.Errorf("cbor: unsupported map key type %T", k)
+			}
+			if _, ok := m[k]; ok {
+				return nil, nil, fmt.Errorf("cbor: duplicate map key %v", k)
+			}
+			v, buf, err = cborDecode(buf, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[k] = v
+		}
+		return m, buf, nil
+	}
+	return nil, nil, fmt.Errorf("cbor: unsupported major type %d", major)
+}
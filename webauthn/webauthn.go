@@ -0,0 +1,263 @@
+// Package webauthn implements verification of WebAuthn public key credentials,
+// "passkeys", for logins to the web interfaces.
+//
+// Only the relying party side is implemented: checking responses of the browser
+// calls navigator.credentials.create (registration) and
+// navigator.credentials.get (authentication). Attestation statements are not
+// verified, registrations request "none" attestation. Supported signature
+// algorithms are ES256, EdDSA (Ed25519) and RS256.
+package webauthn
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers, for the supported signature algorithms.
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// Algorithms are the supported algorithms, in order of preference, for
+// registration options.
+var Algorithms = []int{AlgES256, AlgEdDSA, AlgRS256}
+
+// Flags in authenticator data.
+const (
+	flagUserPresent            = 0x01
+	flagUserVerified           = 0x04
+	flagAttestedCredentialData = 0x40
+)
+
+var (
+	ErrUserVerification = errors.New("webauthn: user verification required")
+	ErrSignCount        = errors.New("webauthn: signature counter did not increase, authenticator may be cloned")
+)
+
+// NewChallenge returns a new random challenge, for a registration or
+// authentication ceremony.
+func NewChallenge() []byte {
+	buf := make([]byte, 32)
+	cryptorand.Read(buf)
+	return buf
+}
+
+// Credential is a newly registered public key credential.
+type Credential struct {
+	ID        []byte // Credential ID, chosen by the authenticator.
+	PublicKey []byte // COSE-encoded public key.
+	SignCount uint32 // Signature counter, zero if the authenticator does not implement one.
+}
+
+type clientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+// checkClientData verifies the JSON client data collected by the browser.
+func checkClientData(buf []byte, typ string, challenge []byte, origin string) error {
+	var cd clientData
+	if err := json.Unmarshal(buf, &cd); err != nil {
+		return fmt.Errorf("webauthn: parsing client data: %v", err)
+	}
+	if cd.Type != typ {
+		return fmt.Errorf("webauthn: client data has type %q, expected %q", cd.Type, typ)
+	}
+	c, err := base64.RawURLEncoding.DecodeString(cd.Challenge)
+	if err != nil || subtle.ConstantTimeCompare(c, challenge) != 1 {
+		return fmt.Errorf("webauthn: challenge mismatch")
+	}
+	if cd.Origin != origin {
+		return fmt.Errorf("webauthn: client data has origin %q, expected %q", cd.Origin, origin)
+	}
+	return nil
+}
+
+type authData struct {
+	flags     byte
+	signCount uint32
+
+	// Only for registration.
+	credentialID []byte
+	publicKey    []byte
+}
+
+// parseAuthData parses and checks authenticator data for the relying party.
+func parseAuthData(buf []byte, rpID string) (authData, error) {
+	if len(buf) < 37 {
+		return authData{}, fmt.Errorf("webauthn: authenticator data too short")
+	}
+	rpIDHash := sha256.Sum256([]byte(rpID))
+	if !bytes.Equal(buf[:32], rpIDHash[:]) {
+		return authData{}, fmt.Errorf("webauthn: authenticator data for other relying party")
+	}
+	ad := authData{flags: buf[32], signCount: binary.BigEndian.Uint32(buf[33:37])}
+	if ad.flags&flagUserPresent == 0 {
+		return authData{}, fmt.Errorf("webauthn: user not present")
+	}
+	if ad.flags&flagAttestedCredentialData == 0 {
+		return ad, nil
+	}
+
+	// Attested credential data: AAGUID, credential ID with length, public key.
+	buf = buf[37:]
+	if len(buf) < 18 {
+		return authData{}, fmt.Errorf("webauthn: attested credential data too short")
+	}
+	n := int(binary.BigEndian.Uint16(buf[16:18]))
+	buf = buf[18:]
+	if n == 0 || n > 1023 || len(buf) < n {
+		return authData{}, fmt.Errorf("webauthn: bad credential id length")
+	}
+	ad.credentialID = buf[:n]
+	buf = buf[n:]
+	_, rest, err := cborDecode(buf, 0)
+	if err != nil {
+		return authData{}, fmt.Errorf("webauthn: parsing credential public key: %v", err)
+	}
+	ad.publicKey = buf[:len(buf)-len(rest)]
+	// Any remaining data holds extensions, which we ignore.
+	return ad, nil
+}
+
+// ParseCreation verifies the response of a registration ceremony, returning the
+// new credential to store. The challenge must be the one passed to the browser.
+// RPID is the relying party, typically the hostname of the web interface, and
+// origin the expected origin of the web page, e.g. "https://mail.example.org".
+func ParseCreation(rpID, origin string, challenge, clientDataJSON, attestationObject []byte) (Credential, error) {
+	if err := checkClientData(clientDataJSON, "webauthn.create", challenge, origin); err != nil {
+		return Credential{}, err
+	}
+	v, rest, err := cborDecode(attestationObject, 0)
+	if err != nil {
+		return Credential{}, fmt.Errorf("webauthn: parsing attestation object: %v", err)
+	} else if len(rest) != 0 {
+		return Credential{}, fmt.Errorf("webauthn: trailing data after attestation object")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return Credential{}, fmt.Errorf("webauthn: attestation object not a map")
+	}
+	buf, ok := m["authData"].([]byte)
+	if !ok {
+		return Credential{}, fmt.Errorf("webauthn: attestation object without authenticator data")
+	}
+	ad, err := parseAuthData(buf, rpID)
+	if err != nil {
+		return Credential{}, err
+	}
+	if ad.credentialID == nil {
+		return Credential{}, fmt.Errorf("webauthn: authenticator data without attested credential")
+	}
+	if _, err := parsePublicKey(ad.publicKey); err != nil {
+		return Credential{}, err
+	}
+	return Credential{ad.credentialID, ad.publicKey, ad.signCount}, nil
+}
+
+// VerifyAssertion verifies the response of an authentication ceremony for the
+// credential with publicKey and last known signCount, returning the new signature
+// counter to store. If requireUV is set, the authenticator must have verified the
+// user, e.g. with a PIN or biometrics, as needed for logins without password.
+func VerifyAssertion(rpID, origin string, challenge, publicKey []byte, signCount uint32, clientDataJSON, authenticatorData, signature []byte, requireUV bool) (uint32, error) {
+	if err := checkClientData(clientDataJSON, "webauthn.get", challenge, origin); err != nil {
+		return 0, err
+	}
+	ad, err := parseAuthData(authenticatorData, rpID)
+	if err != nil {
+		return 0, err
+	}
+	if requireUV && ad.flags&flagUserVerified == 0 {
+		return 0, ErrUserVerification
+	}
+	pk, err := parsePublicKey(publicKey)
+	if err != nil {
+		return 0, err
+	}
+
+	cdHash := sha256.Sum256(clientDataJSON)
+	msg := append(append([]byte{}, authenticatorData...), cdHash[:]...)
+	h := sha256.Sum256(msg)
+	var ok bool
+	switch k := pk.(type) {
+	case *ecdsa.PublicKey:
+		ok = ecdsa.VerifyASN1(k, h[:], signature)
+	case ed25519.PublicKey:
+		ok = ed25519.Verify(k, msg, signature)
+	case *rsa.PublicKey:
+		ok = rsa.VerifyPKCS1v15(k, crypto.SHA256, h[:], signature) == nil
+	}
+	if !ok {
+		return 0, fmt.Errorf("webauthn: bad signature")
+	}
+
+	// Authenticators without counter always send zero.
+	if (ad.signCount != 0 || signCount != 0) && ad.signCount <= signCount {
+		return 0, ErrSignCount
+	}
+	return ad.signCount, nil
+}
+
+// parsePublicKey parses a COSE-encoded public key, RFC 9053.
+func parsePublicKey(buf []byte) (crypto.PublicKey, error) {
+	v, rest, err := cborDecode(buf, 0)
+	if err != nil {
+		return nil, fmt.Errorf("webauthn: parsing public key: %v", err)
+	} else if len(rest) != 0 {
+		return nil, fmt.Errorf("webauthn: trailing data after public key")
+	}
+	m, ok := v.(map[any]any)
+	if !ok {
+		return nil, fmt.Errorf("webauthn: public key not a map")
+	}
+	kty, _ := m[int64(1)].(int64)
+	alg, _ := m[int64(3)].(int64)
+	crv, _ := m[int64(-1)].(int64)
+	switch {
+	case kty == 2 && alg == AlgES256 && crv == 1:
+		x, _ := m[int64(-2)].([]byte)
+		y, _ := m[int64(-3)].([]byte)
+		if len(x) != 32 || len(y) != 32 {
+			return nil, fmt.Errorf("webauthn: bad ec2 public key coordinates")
+		}
+		k := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !k.Curve.IsOnCurve(k.X, k.Y) {
+			return nil, fmt.Errorf("webauthn: ec2 public key not on curve")
+		}
+		return k, nil
+	case kty == 1 && alg == AlgEdDSA && crv == 6:
+		x, _ := m[int64(-2)].([]byte)
+		if len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("webauthn: bad ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	case kty == 3 && alg == AlgRS256:
+		n, _ := m[int64(-1)].([]byte)
+		e, _ := m[int64(-2)].([]byte)
+		if len(n) < 2048/8 || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("webauthn: bad or too small rsa public key")
+		}
+		var ev int
+		for _, b := range e {
+			ev = ev<<8 | int(b)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: ev}, nil
+	}
+	return nil, fmt.Errorf("webauthn: unsupported public key type %d with algorithm %d", kty, alg)
+}
@@ -0,0 +1,247 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// cborEncode encodes the types returned by cborDecode, for tests.
+func cborEncode(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			return binary.BigEndian.AppendUint16([]byte{major<<5 | 25}, uint16(n))
+		case n < 1<<32:
+			return binary.BigEndian.AppendUint32([]byte{major<<5 | 26}, uint32(n))
+		}
+		return binary.BigEndian.AppendUint64([]byte{major<<5 | 27}, n)
+	}
+	switch x := v.(type) {
+	case int:
+		return cborEncode(int64(x))
+	case int64:
+		if x >= 0 {
+			return head(0, uint64(x))
+		}
+		return head(1, uint64(-1-x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case []any:
+		buf := head(4, uint64(len(x)))
+		for _, e := range x {
+			buf = append(buf, cborEncode(e)...)
+		}
+		return buf
+	case [][2]any:
+		// Map, as ordered list of key/value pairs.
+		buf := head(5, uint64(len(x)))
+		for _, kv := range x {
+			buf = append(buf, cborEncode(kv[0])...)
+			buf = append(buf, cborEncode(kv[1])...)
+		}
+		return buf
+	case bool:
+		if x {
+			return []byte{0xf5}
+		}
+		return []byte{0xf4}
+	case nil:
+		return []byte{0xf6}
+	}
+	panic("unsupported type")
+}
+
+func TestCBOR(t *testing.T) {
+	tests := []struct {
+		v   any
+		exp any
+	}{
+		{0, int64(0)},
+		{23, int64(23)},
+		{24, int64(24)},
+		{1000000, int64(1000000)},
+		{-1, int64(-1)},
+		{-1000, int64(-1000)},
+		{[]byte("test"), []byte("test")},
+		{"☺", "☺"},
+		{[]any{1, "a", true, nil}, []any{int64(1), "a", true, nil}},
+		{[][2]any{{1, 2}, {"a", []any{}}}, map[any]any{int64(1): int64(2), "a": []any{}}},
+	}
+	for _, tc := range tests {
+		buf := cborEncode(tc.v)
+		v, rest, err := cborDecode(buf, 0)
+		if err != nil {
+			t.Fatalf("decode %v: %v", tc.v, err)
+		}
+		if len(rest) != 0 || !reflect.DeepEqual(v, tc.exp) {
+			t.Fatalf("decode %v: got %#v, rest %x, expected %#v", tc.v, v, rest, tc.exp)
+		}
+	}
+
+	bad := [][]byte{
+		{},
+		{0x18},                   // Truncated argument.
+		{0x42, 'a'},              // Truncated byte string.
+		{0x9f},                   // Indefinite length array.
+		{0xc0, 0x00},             // Tag.
+		{0xf9, 0x00, 0x00},       // Float.
+		{0xa2, 0x01, 0x01, 0x01}, // Duplicate map key.
+		{0x9a, 0xff, 0xff, 0xff, 0xff},
+	}
+	for _, buf := range bad {
+		if _, _, err := cborDecode(buf, 0); err == nil {
+			t.Fatalf("decode %x: expected error", buf)
+		}
+	}
+}
+
+func TestWebAuthn(t *testing.T) {
+	const rpID = "mox.example"
+	const origin = "https://mox.example"
+
+	clientDataJSON := func(typ string, challenge []byte, origin string) []byte {
+		buf, err := json.Marshal(map[string]any{
+			"type":      typ,
+			"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+			"origin":    origin,
+		})
+		if err != nil {
+			t.Fatalf("marshal client data: %v", err)
+		}
+		return buf
+	}
+	authenticatorData := func(rpID string, flags byte, signCount uint32, credID, coseKey []byte) []byte {
+		h := sha256.Sum256([]byte(rpID))
+		buf := append(h[:], flags)
+		buf = binary.BigEndian.AppendUint32(buf, signCount)
+		if credID != nil {
+			buf = append(buf, make([]byte, 16)...) // AAGUID.
+			buf = binary.BigEndian.AppendUint16(buf, uint16(len(credID)))
+			buf = append(buf, credID...)
+			buf = append(buf, coseKey...)
+		}
+		return buf
+	}
+
+	testKey := func(coseKey []byte, sign func(msg []byte) []byte) {
+		t.Helper()
+
+		credID := []byte("credential-1")
+		challenge := NewChallenge()
+		attObj := cborEncode([][2]any{
+			{"fmt", "none"},
+			{"attStmt", [][2]any{}},
+			{"authData", authenticatorData(rpID, flagUserPresent|flagAttestedCredentialData, 1, credID, coseKey)},
+		})
+		cdata := clientDataJSON("webauthn.create", challenge, origin)
+
+		if _, err := ParseCreation(rpID, origin, NewChallenge(), cdata, attObj); err == nil {
+			t.Fatalf("creation with other challenge accepted")
+		}
+		if _, err := ParseCreation(rpID, "https://other.example", challenge, cdata, attObj); err == nil {
+			t.Fatalf("creation with other origin accepted")
+		}
+		if _, err := ParseCreation("other.example", origin, challenge, cdata, attObj); err == nil {
+			t.Fatalf("creation for other rp accepted")
+		}
+		cred, err := ParseCreation(rpID, origin, challenge, cdata, attObj)
+		if err != nil {
+			t.Fatalf("parse creation: %v", err)
+		}
+		if string(cred.ID) != string(credID) || string(cred.PublicKey) != string(coseKey) || cred.SignCount != 1 {
+			t.Fatalf("unexpected credential %#v", cred)
+		}
+
+		assert := func(flags byte, signCount uint32, requireUV bool) (uint32, error) {
+			challenge := NewChallenge()
+			cdata := clientDataJSON("webauthn.get", challenge, origin)
+			ad := authenticatorData(rpID, flags, signCount, nil, nil)
+			cdHash := sha256.Sum256(cdata)
+			sig := sign(append(append([]byte{}, ad...), cdHash[:]...))
+			return VerifyAssertion(rpID, origin, challenge, cred.PublicKey, cred.SignCount, cdata, ad, sig, requireUV)
+		}
+		n, err := assert(flagUserPresent|flagUserVerified, 2, true)
+		if err != nil || n != 2 {
+			t.Fatalf("verify assertion: %v, counter %d", err, n)
+		}
+		if _, err := assert(flagUserPresent, 2, true); !errors.Is(err, ErrUserVerification) {
+			t.Fatalf("got err %v, expected ErrUserVerification", err)
+		}
+		if _, err := assert(flagUserPresent, 1, false); !errors.Is(err, ErrSignCount) {
+			t.Fatalf("got err %v, expected ErrSignCount", err)
+		}
+
+		// Signature over other data.
+		challenge = NewChallenge()
+		cdata = clientDataJSON("webauthn.get", challenge, origin)
+		ad := authenticatorData(rpID, flagUserPresent, 2, nil, nil)
+		if _, err := VerifyAssertion(rpID, origin, challenge, cred.PublicKey, cred.SignCount, cdata, ad, sign([]byte("other")), false); err == nil {
+			t.Fatalf("bad signature accepted")
+		}
+		// Type must be for authentication.
+		cdata = clientDataJSON("webauthn.create", challenge, origin)
+		cdHash := sha256.Sum256(cdata)
+		if _, err := VerifyAssertion(rpID, origin, challenge, cred.PublicKey, cred.SignCount, cdata, ad, sign(append(append([]byte{}, ad...), cdHash[:]...)), false); err == nil {
+			t.Fatalf("assertion with creation client data accepted")
+		}
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generate ecdsa key: %v", err)
+	}
+	ecCOSE := cborEncode([][2]any{
+		{1, 2},
+		{3, AlgES256},
+		{-1, 1},
+		{-2, ecKey.X.FillBytes(make([]byte, 32))},
+		{-3, ecKey.Y.FillBytes(make([]byte, 32))},
+	})
+	testKey(ecCOSE, func(msg []byte) []byte {
+		h := sha256.Sum256(msg)
+		sig, err := ecdsa.SignASN1(cryptorand.Reader, ecKey, h[:])
+		if err != nil {
+			t.Fatalf("ecdsa sign: %v", err)
+		}
+		return sig
+	})
+
+	edPub, edPriv, err := ed25519.GenerateKey(cryptorand.Reader)
+	if err != nil {
+		t.Fatalf("generate ed25519 key: %v", err)
+	}
+	edCOSE := cborEncode([][2]any{
+		{1, 1},
+		{3, AlgEdDSA},
+		{-1, 6},
+		{-2, []byte(edPub)},
+	})
+	testKey(edCOSE, func(msg []byte) []byte {
+		sig, err := edPriv.Sign(cryptorand.Reader, msg, crypto.Hash(0))
+		if err != nil {
+			t.Fatalf("ed25519 sign: %v", err)
+		}
+		return sig
+	})
+
+	// Unsupported key type.
+	if _, err := parsePublicKey(cborEncode([][2]any{{1, 2}, {3, -35}, {-1, 2}})); err == nil {
+		t.Fatalf("unsupported key accepted")
+	}
+}
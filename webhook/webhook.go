@@ -52,6 +52,28 @@ const (
 	EventUnrecognized OutgoingEvent = "unrecognized"
 )
 
+// BounceClass is the classification of a delivery failure, based on the SMTP
+// codes and response text from the remote server, or from an incoming DSN.
+type BounceClass string
+
+const (
+	// Recipient address does not exist or does not accept email anymore. Sending to
+	// it again is pointless.
+	BounceHard BounceClass = "hard"
+
+	// Temporary condition at the recipient, e.g. a full mailbox, or a remote server
+	// that is unavailable. Delivery may succeed later.
+	BounceSoft BounceClass = "soft"
+
+	// Our server (its IP or domain) is blocked by the recipient, e.g. due to listing
+	// in a DNS blocklist or bad reputation. Not specific to the recipient address.
+	BounceBlock BounceClass = "block"
+
+	// Message was rejected due to a policy, e.g. a content filter considering it
+	// spam, failed SPF/DKIM/DMARC, or a message that is too large.
+	BouncePolicy BounceClass = "policy"
+)
+
 // Outgoing is the payload sent to webhook URLs for events about outgoing deliveries.
 type Outgoing struct {
 	Version          int               // Format of hook, currently 0.
@@ -65,8 +87,24 @@ type Outgoing struct {
 	WebhookQueued    time.Time         // When webhook was first queued for delivery.
 	SMTPCode         int               // Optional, for errors only, e.g. 451, 550. See package smtp for definitions.
 	SMTPEnhancedCode string            // Optional, for errors only, e.g. 5.1.1.
+	BounceClass      BounceClass       // Optional, for failed and delayed events with an SMTP error: "hard", "soft", "block" or "policy".
 	Error            string            // Error message while delivering, or from DSN from remote, if any.
 	Extra            map[string]string // Extra fields set for message during submit, through webapi call or through X-Mox-Extra-* headers during SMTP submission.
+
+	Recipient   string     // Address of the recipient this event is about, as used in SMTP RCPT TO.
+	Attempts    int        // Number of delivery attempts made so far.
+	MaxAttempts int        // Maximum number of delivery attempts before giving up, for delayed events.
+	NextAttempt *time.Time // When the next delivery attempt is scheduled, for delayed events.
+
+	// Details about the last delivery attempt, only set for events caused by a
+	// delivery attempt from the queue, not for DSNs.
+
+	RemoteMTA    string   // Host name and IP address of remote server, if a connection was made.
+	SMTPResponse []string // Full response from remote server, one or more lines.
+	TLS          string   // TLS version and cipher suite, e.g. "tls1.3 tls_aes_128_gcm_sha256". Empty if TLS was not used.
+	MTASTS       bool     // Whether the TLS connection was verified according to an MTA-STS policy.
+	DANE         bool     // Whether the TLS connection was verified with DANE.
+	Transcript   string   // SMTP protocol transcript, without authentication and message data. Only included if enabled for the webhook.
 }
 
 // Incoming is the data sent to a webhook for incoming deliveries over SMTP.
@@ -1,12 +1,15 @@
 package webmail
 
 import (
+	"bytes"
+	"cmp"
 	"context"
 	cryptorand "crypto/rand"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"log/slog"
 	"maps"
@@ -32,6 +35,8 @@ import (
 	"github.com/mjl-/sherpa"
 	"github.com/mjl-/sherpadoc"
 	"github.com/mjl-/sherpaprom"
+	"golang.org/x/crypto/openpgp"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
 
 	"github.com/mjl-/mox/admin"
 	"github.com/mjl-/mox/config"
@@ -45,12 +50,15 @@ import (
 	"github.com/mjl-/mox/moxvar"
 	"github.com/mjl-/mox/mtasts"
 	"github.com/mjl-/mox/mtastsdb"
+	"github.com/mjl-/mox/oidc"
 	"github.com/mjl-/mox/queue"
 	"github.com/mjl-/mox/smtp"
 	"github.com/mjl-/mox/smtpclient"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/webauth"
 	"github.com/mjl-/mox/webops"
+	"github.com/mjl-/mox/webpush"
+	"github.com/mjl-/mox/wkd"
 )
 
 //go:embed api.json
@@ -109,12 +117,14 @@ func (w Webmail) LoginPrep(ctx context.Context) string {
 }
 
 // Login returns a session token for the credentials, or fails with error code
-// "user:badLogin". Call LoginPrep to get a loginToken.
-func (w Webmail) Login(ctx context.Context, loginToken, username, password string) store.CSRFToken {
+// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+// authentication is enabled, totp must be a code from the authenticator app,
+// otherwise the login fails with error code "user:totpRequired".
+func (w Webmail) Login(ctx context.Context, loginToken, username, password, totp string) store.CSRFToken {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	log := reqInfo.Log
 
-	csrfToken, err := webauth.Login(ctx, log, webauth.Accounts, "webmail", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password)
+	csrfToken, err := webauth.Login(ctx, log, webauth.Accounts, "webmail", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password, totp)
 	if _, ok := err.(*sherpa.Error); ok {
 		panic(err)
 	}
@@ -122,6 +132,43 @@ func (w Webmail) Login(ctx context.Context, loginToken, username, password strin
 	return csrfToken
 }
 
+// PasskeyLoginStart returns the parameters for the browser to login with a
+// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+// of the browser.
+func (w Webmail) PasskeyLoginStart(ctx context.Context, loginToken string) webauth.PasskeyGetOptions {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	opts, err := webauth.PasskeyLoginStart(ctx, log, "webmail", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken)
+	if _, ok := err.(*sherpa.Error); ok {
+		panic(err)
+	}
+	xcheckf(ctx, err, "passkey login")
+	return opts
+}
+
+// PasskeyLogin returns a session token for a passkey login started with
+// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+// password, the passkey must verify the user, e.g. with a PIN. With username and
+// password, the passkey is the second factor.
+func (w Webmail) PasskeyLogin(ctx context.Context, loginToken, username, password string, assertion webauth.PasskeyAssertion) store.CSRFToken {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	csrfToken, err := webauth.PasskeyLogin(ctx, log, webauth.Accounts, "webmail", w.cookiePath, w.isForwarded, reqInfo.Response, reqInfo.Request, loginToken, username, password, assertion)
+	if _, ok := err.(*sherpa.Error); ok {
+		panic(err)
+	}
+	xcheckf(ctx, err, "passkey login")
+	return csrfToken
+}
+
+// OIDCEnabled returns whether logging in through an OpenID Connect identity
+// provider is possible, by sending the browser to "oidc/login".
+func (Webmail) OIDCEnabled(ctx context.Context) bool {
+	return oidc.Enabled()
+}
+
 // Logout invalidates the session token.
 func (w Webmail) Logout(ctx context.Context) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
@@ -183,6 +230,8 @@ func (Webmail) ParsedMessage(ctx context.Context, msgID int64) (pm ParsedMessage
 		if len(pm.envelope.From) == 1 {
 			pm.ViewMode, err = fromAddrViewMode(tx, pm.envelope.From[0])
 			xcheckf(ctx, err, "looking up view mode for from address")
+			pm.SMIMEPinned, err = fromAddrSMIMEPin(tx, pm.envelope.From[0])
+			xcheckf(ctx, err, "looking up s/mime pin for from address")
 		}
 	})
 	return
@@ -216,6 +265,21 @@ func fromAddrViewMode(tx *bstore.Tx, from MessageAddress) (store.ViewMode, error
 	return fas.ViewMode, nil
 }
 
+// fromAddrSMIMEPin returns the fingerprint of the S/MIME key pinned for the from
+// address, or an empty string.
+func fromAddrSMIMEPin(tx *bstore.Tx, from MessageAddress) (string, error) {
+	lp, err := smtp.ParseLocalpart(from.User)
+	if err != nil {
+		return "", nil
+	}
+	pin := store.SMIMEPin{FromAddress: smtp.NewAddress(lp, from.Domain).Pack(true)}
+	err = tx.Get(&pin)
+	if err == bstore.ErrAbsent {
+		return "", nil
+	}
+	return pin.Fingerprint, err
+}
+
 // FromAddressSettingsSave saves per-"From"-address settings.
 func (Webmail) FromAddressSettingsSave(ctx context.Context, fas store.FromAddressSettings) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
@@ -236,6 +300,46 @@ func (Webmail) FromAddressSettingsSave(ctx context.Context, fas store.FromAddres
 	})
 }
 
+// SMIMEPin pins the key of the certificate that signed the message for the From
+// address of the message. Messages from the address signed with another key are
+// shown with a warning. Only messages with a valid signature by a certificate for
+// the From address can be pinned.
+func (Webmail) SMIMEPin(ctx context.Context, msgID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+	acc := reqInfo.Account
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		m := xmessageID(ctx, tx, msgID)
+
+		state := msgState{acc: acc}
+		defer state.clear()
+		pm, err := parsedMessage(log, &m, &state, false, true, false)
+		xcheckf(ctx, err, "parsing message")
+		if pm.smime == nil {
+			xcheckuserf(ctx, errors.New("message not signed with s/mime"), "pinning certificate")
+		} else if !pm.smime.Valid || !pm.smime.SignerFrom {
+			xcheckuserf(ctx, errors.New("signature not valid for from address"), "pinning certificate")
+		}
+
+		from := pm.envelope.From[0]
+		lp, err := smtp.ParseLocalpart(from.User)
+		xcheckuserf(ctx, err, "parsing from address")
+		pin := store.SMIMEPin{FromAddress: smtp.NewAddress(lp, from.Domain).Pack(true)}
+		if err := tx.Get(&pin); err == bstore.ErrAbsent {
+			pin.Fingerprint = pm.smime.Fingerprint
+			err := tx.Insert(&pin)
+			xcheckf(ctx, err, "inserting pin")
+		} else {
+			xcheckf(ctx, err, "get pin")
+			pin.Fingerprint = pm.smime.Fingerprint
+			pin.Updated = time.Now()
+			err := tx.Update(&pin)
+			xcheckf(ctx, err, "updating pin")
+		}
+	})
+}
+
 // MessageFindMessageID looks up a message by Message-Id header, and returns the ID
 // of the message in storage. Used when opening a previously saved draft message
 // for editing again.
@@ -263,6 +367,21 @@ func (Webmail) MessageFindMessageID(ctx context.Context, messageID string) (id i
 	return
 }
 
+// MessageJunkVerdict returns the outcome of the junk analysis done when the
+// message was delivered over SMTP, with the signals and junk filter words that
+// contributed, for explaining why a message was (not) marked as junk. Returns
+// nil for messages without stored verdict, e.g. sent or imported messages.
+func (Webmail) MessageJunkVerdict(ctx context.Context, msgID int64) (verdict *store.MessageJunkVerdict) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m := xmessageID(ctx, tx, msgID)
+		verdict = m.JunkVerdict
+	})
+	return
+}
+
 // ComposeMessage is a message to be composed, for saving draft messages.
 type ComposeMessage struct {
 	From              string
@@ -274,6 +393,7 @@ type ComposeMessage struct {
 	TextBody          string
 	ResponseMessageID int64 // If set, this was a reply or forward, based on IsForward.
 	DraftMessageID    int64 // If set, previous draft message that will be removed after composing new message.
+	DraftID           int64 // If set, attachments of the autosaved draft are added to the message.
 }
 
 // MessageCompose composes a message and saves it to the mailbox. Used for
@@ -414,12 +534,45 @@ func (w Webmail) MessageCompose(ctx context.Context, m ComposeMessage, mailboxID
 			}
 		})
 	}
+	var draftAttachments []store.DraftAttachment
+	if m.DraftID > 0 {
+		xdbread(ctx, acc, func(tx *bstore.Tx) {
+			xdraftGet(ctx, tx, m.DraftID)
+			draftAttachments = xdraftAttachments(ctx, tx, m.DraftID)
+		})
+	}
+
 	xc.Header("MIME-Version", "1.0")
 	textBody, ct, cte := xc.TextPart("plain", m.TextBody)
-	xc.Header("Content-Type", ct)
-	xc.Header("Content-Transfer-Encoding", cte)
-	xc.Line()
-	xc.Write([]byte(textBody))
+	if len(draftAttachments) == 0 {
+		xc.Header("Content-Type", ct)
+		xc.Header("Content-Transfer-Encoding", cte)
+		xc.Line()
+		xc.Write([]byte(textBody))
+	} else {
+		mp := multipart.NewWriter(xc)
+		xc.Header("Content-Type", fmt.Sprintf(`multipart/mixed; boundary="%s"`, mp.Boundary()))
+		xc.Line()
+		tp, err := mp.CreatePart(textproto.MIMEHeader{"Content-Type": {ct}, "Content-Transfer-Encoding": {cte}})
+		xcheckf(ctx, err, "adding text part to message")
+		_, err = tp.Write(textBody)
+		xcheckf(ctx, err, "writing text part")
+		for _, a := range draftAttachments {
+			ahdr := textproto.MIMEHeader{}
+			ahdr.Set("Content-Type", a.ContentType)
+			ahdr.Set("Content-Transfer-Encoding", "base64")
+			ahdr.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Filename}))
+			ap, err := mp.CreatePart(ahdr)
+			xcheckf(ctx, err, "adding attachment part to message")
+			wc := moxio.Base64Writer(ap)
+			_, err = wc.Write(a.Data)
+			xcheckf(ctx, err, "adding attachment")
+			err = wc.Close()
+			xcheckf(ctx, err, "flushing attachment")
+		}
+		err = mp.Close()
+		xcheckf(ctx, err, "writing mime multipart")
+	}
 	xc.Flush()
 
 	var nm store.Message
@@ -505,8 +658,11 @@ type SubmitMessage struct {
 	ReplyTo                   string // If non-empty, Reply-To header to add to message.
 	Subject                   string
 	TextBody                  string
+	HTMLBody                  string       // If set, sent as alternative to TextBody, after sanitizing with the HTML policy from the settings.
+	InlineFiles               []InlineFile // Images referenced from HTMLBody with "cid:" URIs.
 	Attachments               []File
 	ForwardAttachments        ForwardAttachments
+	AttachMessageID           int64 // If set, this message is attached in full as message/rfc822, e.g. when forwarding as attachment.
 	IsForward                 bool
 	ResponseMessageID         int64      // If set, this was a reply or forward, based on IsForward.
 	UserAgent                 string     // User-Agent header added if not empty.
@@ -515,6 +671,19 @@ type SubmitMessage struct {
 	ArchiveThread             bool       // If set, thread is archived after sending message.
 	ArchiveReferenceMailboxID int64      // If ArchiveThread is set, thread messages from this mailbox ID are moved to the archive mailbox ID. E.g. of Inbox.
 	DraftMessageID            int64      // If set, draft message that will be removed after sending.
+	DraftID                   int64      // If set, attachments of the autosaved draft are added to the message, and the draft is removed after sending.
+	PGPSign                   bool       // Sign message with OpenPGP key of account for From address.
+	PGPEncrypt                bool       // Encrypt message with OpenPGP keys of recipients, from account or Web Key Directory.
+	PGPPassphrase             string     // For decrypting private key for signing.
+}
+
+// SubmitResult is the result of submitting a message. If sending can be undone,
+// UndoUntil is set and the message can be canceled with MessageSubmitUndo until
+// that time.
+type SubmitResult struct {
+	QueueMsgIDs   []int64    // Messages in the delivery queue, one per recipient.
+	SentMessageID int64      // Message added to the Sent mailbox, if any.
+	UndoUntil     *time.Time // Time until delivery is held back, if undo is enabled in the settings.
 }
 
 // ForwardAttachments references attachments by a list of message.Part paths.
@@ -530,6 +699,41 @@ type File struct {
 	DataURI  string // Full data of the attachment, with base64 encoding and including content-type.
 }
 
+// InlineFile is an image for a SubmitMessage with an HTML body, referenced from
+// the HTML with a "cid:" URI, e.g. "cid:image1@example".
+type InlineFile struct {
+	ContentID string // Without "cid:" and "<>", e.g. "image1@example".
+	Filename  string
+	DataURI   string // Full data of the image, with base64 encoding and including content-type.
+}
+
+// parseDataURI parses a data URI with base64-encoded data, as used for
+// attachments, returning the content-type and the still base64-encoded data.
+func parseDataURI(s string) (ct, data string, rerr error) {
+	if !strings.HasPrefix(s, "data:") {
+		return "", "", errors.New("missing data: in datauri")
+	}
+	s = s[len("data:"):]
+	t := strings.SplitN(s, ",", 2)
+	if len(t) != 2 {
+		return "", "", errors.New("missing comma in datauri")
+	}
+	if !strings.HasSuffix(t[0], "base64") {
+		return "", "", errors.New("missing base64 in datauri")
+	}
+	ct = strings.TrimSuffix(t[0], "base64")
+	ct = strings.TrimSuffix(ct, ";")
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+
+	// Ensure base64 is valid, callers use the original string.
+	if _, err := io.Copy(io.Discard, base64.NewDecoder(base64.StdEncoding, strings.NewReader(t[1]))); err != nil {
+		return "", "", fmt.Errorf("parsing base64: %v", err)
+	}
+	return ct, t[1], nil
+}
+
 // parseAddress expects either a plain email address like "user@domain", or a
 // single address as used in a message header, like "name <user@domain>".
 func parseAddress(msghdr string) (message.NameAddress, error) {
@@ -547,6 +751,39 @@ func parseAddress(msghdr string) (message.NameAddress, error) {
 	return message.NameAddress{DisplayName: a.Name, Address: path}, nil
 }
 
+// identityForFrom returns the configured identity of the account for the message
+// From address, or nil. An identity with a display name only matches a From
+// address with the same display name.
+func identityForFrom(accConf config.Account, from message.NameAddress) *config.Identity {
+	for i, ident := range accConf.Identities {
+		addr, err := smtp.ParseAddress(ident.Address)
+		if err != nil || addr != from.Address || ident.DisplayName != "" && ident.DisplayName != from.DisplayName {
+			continue
+		}
+		return &accConf.Identities[i]
+	}
+	return nil
+}
+
+// identityHTML returns an HTML version of the plain text message, with the text
+// signature of the identity replaced by its HTML signature. If the identity has no
+// text signature, the HTML signature is added at the end. If the text signature
+// was removed from the message, no HTML signature is added.
+func identityHTML(text string, ident config.Identity) string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	xhtml := html.EscapeString
+	sig := strings.Join(ident.Signature, "\n")
+	var body string
+	if sig == "" {
+		body = xhtml(text) + "<div>" + ident.SignatureHTML + "</div>\n"
+	} else if i := strings.LastIndex(text, sig); i >= 0 {
+		body = xhtml(text[:i]) + "<div>" + ident.SignatureHTML + "</div>\n" + xhtml(text[i+len(sig):])
+	} else {
+		body = xhtml(text)
+	}
+	return "<!doctype html>\n<html>\n<head><meta charset=\"utf-8\"></head>\n<body style=\"white-space: pre-wrap\">" + body + "</body>\n</html>\n"
+}
+
 func xmailboxID(ctx context.Context, tx *bstore.Tx, mailboxID int64) store.Mailbox {
 	if mailboxID == 0 {
 		xcheckuserf(ctx, errors.New("invalid zero mailbox ID"), "getting mailbox")
@@ -589,6 +826,35 @@ func xrandom(ctx context.Context, n int) []byte {
 	return buf
 }
 
+// MessageResponse returns a prepared reply or forward for a message. If selection
+// is not empty, it is quoted instead of the text of the message, e.g. text the
+// user selected. The response is sent with MessageSubmit, with ResponseMessageID
+// set, and for forwards optionally with the attachments in ForwardAttachments, or
+// the entire message with AttachMessageID.
+func (Webmail) MessageResponse(ctx context.Context, messageID int64, mode ResponseMode, selection string) (rc ResponseCompose) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+	acc := reqInfo.Account
+
+	accConf, _ := acc.Conf()
+
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m := xmessageID(ctx, tx, messageID)
+
+		settings := store.Settings{ID: 1}
+		err := tx.Get(&settings)
+		xcheckf(ctx, err, "get settings")
+
+		state := msgState{acc: acc, log: log}
+		defer state.clear()
+		pm, err := parsedMessage(log, &m, &state, true, false, false)
+		xcheckf(ctx, err, "parsing message")
+
+		rc = xresponseCompose(ctx, accConf, settings, pm, state.part, mode, selection)
+	})
+	return
+}
+
 // MessageSubmit sends a message by submitting it the outgoing email queue. The
 // message is sent to all addresses listed in the To, Cc and Bcc addresses, without
 // Bcc message header.
@@ -596,7 +862,11 @@ func xrandom(ctx context.Context, n int) []byte {
 // If a Sent mailbox is configured, messages are added to it after submitting
 // to the delivery queue. If Bcc addresses were present, a header is prepended
 // to the message stored in the Sent mailbox.
-func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
+//
+// If UndoSendSeconds is set in the settings and no FutureRelease is requested,
+// delivery is held back for that many seconds, during which MessageSubmitUndo
+// can cancel it.
+func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) (result SubmitResult) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	acc := reqInfo.Account
 	log := reqInfo.Log
@@ -655,13 +925,24 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 		recipients = append(recipients, addr.Address)
 	}
 
-	// Check if from address is allowed for account.
+	// Check if from address is allowed for account, or for an account that delegated
+	// sending. When sending on behalf of another account, the login address is added
+	// as Sender header.
+	var sender *message.NameAddress
 	if ok, disabled := mox.AllowMsgFrom(reqInfo.Account.Name, fromAddr.Address); disabled {
 		metricSubmission.WithLabelValues("domaindisabled").Inc()
 		xcheckuserf(ctx, mox.ErrDomainDisabled, `looking up "from" address for account`)
 	} else if !ok {
-		metricSubmission.WithLabelValues("badfrom").Inc()
-		xcheckuserf(ctx, errors.New("address not found"), `looking up "from" address for account`)
+		delegated, onBehalf := mox.AllowDelegatedMsgFrom(reqInfo.Account.Name, fromAddr.Address)
+		if !delegated {
+			metricSubmission.WithLabelValues("badfrom").Inc()
+			xcheckuserf(ctx, errors.New("address not found"), `looking up "from" address for account`)
+		}
+		if onBehalf {
+			addr, err := smtp.ParseAddress(reqInfo.LoginAddress)
+			xcheckf(ctx, err, "parsing login address")
+			sender = &message.NameAddress{Address: addr}
+		}
 	}
 
 	if len(recipients) == 0 {
@@ -685,6 +966,21 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 		xcheckf(ctx, err, "checking send limit")
 	})
 
+	// Check for anomalies in outgoing messages, a sign of a compromised account.
+	rcpts := make([]smtp.Path, len(recipients))
+	for i, r := range recipients {
+		rcpts[i] = smtp.Path{Localpart: r.Localpart, IPDomain: dns.IPDomain{Domain: r.Domain}}
+	}
+	err = webops.SendAnomalies(ctx, log, acc, rcpts, nil)
+	if errors.Is(err, webops.ErrSendSuspended) {
+		metricSubmission.WithLabelValues("suspended").Inc()
+		xcheckuserf(ctx, err, "checking outgoing messages")
+	} else if errors.Is(err, webops.ErrSendThrottled) {
+		metricSubmission.WithLabelValues("throttled").Inc()
+		xcheckuserf(ctx, err, "checking outgoing messages")
+	}
+	xcheckf(ctx, err, "checking for anomalies in outgoing messages")
+
 	// We only use smtputf8 if we have to, with a utf-8 localpart. For IDNA, we use ASCII domains.
 	smtputf8 := false
 	for _, a := range recipients {
@@ -700,6 +996,56 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 	if !smtputf8 && replyTo != nil && replyTo.Address.Localpart.IsInternational() {
 		smtputf8 = true
 	}
+	if !smtputf8 && sender != nil && sender.Address.Localpart.IsInternational() {
+		smtputf8 = true
+	}
+
+	// Find the OpenPGP keys before composing, so missing keys are reported early.
+	var pgpSigner *openpgp.Entity
+	var pgpTo openpgp.EntityList
+	if m.PGPSign || m.PGPEncrypt {
+		var keys []store.PGPKey
+		xdbread(ctx, acc, func(tx *bstore.Tx) {
+			var err error
+			keys, err = bstore.QueryTx[store.PGPKey](tx).List()
+			xcheckf(ctx, err, "listing pgp keys")
+		})
+
+		// We sign with a private key for the From address.
+		if m.PGPSign {
+			for _, k := range keys {
+				if k.PrivateKey != "" && slices.Contains(k.Addresses, fromAddr.Address.String()) {
+					e, err := k.Entity(m.PGPPassphrase)
+					xcheckuserf(ctx, err, "opening private pgp key")
+					pgpSigner = e
+					break
+				}
+			}
+			if pgpSigner == nil {
+				xcheckuserf(ctx, fmt.Errorf("no private openpgp key for %s", fromAddr.Address), "looking up pgp key")
+			}
+		}
+
+		// When encrypting, we also encrypt to our own keys, so the message in the Sent
+		// mailbox can be read.
+		if m.PGPEncrypt {
+			pgpTo = pgpAddressKeys(log, keys, fromAddr.Address)
+			for _, rcpt := range recipients {
+				l := pgpAddressKeys(log, keys, rcpt)
+				if len(l) == 0 {
+					var err error
+					l, err = wkd.Lookup(ctx, log.Logger, rcpt)
+					if err != nil && !errors.Is(err, wkd.ErrNoKey) {
+						log.Debugx("looking up pgp key in web key directory", err, slog.Any("address", rcpt))
+					}
+				}
+				if len(l) == 0 {
+					xcheckuserf(ctx, fmt.Errorf("no openpgp key for %s", rcpt), "looking up pgp keys of recipients")
+				}
+				pgpTo = append(pgpTo, l...)
+			}
+		}
+	}
 
 	// Create file to compose message into.
 	dataFile, err := store.CreateMessageTemp(log, "webmail-submit")
@@ -745,6 +1091,9 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 
 	// Outer message headers.
 	xc.HeaderAddrs("From", []message.NameAddress{fromAddr})
+	if sender != nil {
+		xc.HeaderAddrs("Sender", []message.NameAddress{*sender})
+	}
 	if replyTo != nil {
 		xc.HeaderAddrs("Reply-To", []message.NameAddress{*replyTo})
 	}
@@ -799,12 +1148,86 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 	}
 	xc.Header("MIME-Version", "1.0")
 
-	if len(m.Attachments) > 0 || len(m.ForwardAttachments.Paths) > 0 {
+	// An HTML body is sent in a multipart/alternative with the text, sanitized
+	// according to the policy in the settings. Otherwise, when sending as an identity
+	// with an HTML signature, an HTML version with the HTML signature is generated.
+	var htmlBody string
+	if m.HTMLBody != "" {
+		settings := store.Settings{ID: 1}
+		xdbread(ctx, acc, func(tx *bstore.Tx) {
+			err := tx.Get(&settings)
+			xcheckf(ctx, err, "get settings")
+		})
+		var err error
+		htmlBody, err = sanitizeComposedHTML(newHTMLPolicy(settings), m.HTMLBody)
+		xcheckuserf(ctx, err, "sanitizing html body")
+	} else if len(m.InlineFiles) > 0 {
+		xcheckuserf(ctx, errors.New("inline files require an html body"), "composing message")
+	} else if accConf, ok := acc.Conf(); ok {
+		if ident := identityForFrom(accConf, fromAddr); ident != nil && ident.SignatureHTML != "" {
+			htmlBody = identityHTML(m.TextBody, *ident)
+		}
+	}
+
+	// With OpenPGP, the body is composed separately, then signed and/or encrypted
+	// and added to the message. Signed text must not be changed in transit, so is
+	// always 7bit.
+	bc := xc
+	var ibuf bytes.Buffer
+	if m.PGPSign || m.PGPEncrypt {
+		bc = message.NewComposer(&ibuf, w.maxMessageSize, false)
+		bc.Require7bit = m.PGPSign
+	}
+
+	// Add the signed or encrypted inner entity to the message. ../rfc/3156:234 ../rfc/3156:326
+	xaddPGP := func(inner []byte) {
 		mp := multipart.NewWriter(xc)
-		xc.Header("Content-Type", fmt.Sprintf(`multipart/mixed; boundary="%s"`, mp.Boundary()))
-		xc.Line()
+		if !m.PGPEncrypt {
+			sig, err := pgpSign(pgpSigner, inner)
+			xcheckf(ctx, err, "signing message")
+			xc.Header("Content-Type", fmt.Sprintf(`multipart/signed; micalg=pgp-sha256; protocol="application/pgp-signature"; boundary="%s"`, mp.Boundary()))
+			xc.Line()
+			// The first part is written as is, it must not be changed by the multipart
+			// writer. The CRLF before the next boundary is not part of the signed data.
+			fmt.Fprintf(xc, "--%s\r\n", mp.Boundary())
+			xc.Write(inner)
+			xc.Write([]byte("\r\n"))
+			hdr := textproto.MIMEHeader{}
+			hdr.Set("Content-Type", `application/pgp-signature; name="signature.asc"`)
+			hdr.Set("Content-Description", "OpenPGP digital signature")
+			hdr.Set("Content-Disposition", `attachment; filename="signature.asc"`)
+			sp, err := mp.CreatePart(hdr)
+			xcheckf(ctx, err, "adding signature part")
+			_, err = sp.Write(sig)
+			xcheckf(ctx, err, "writing signature part")
+		} else {
+			// If signing, the signature is inside the encrypted data.
+			enc, err := pgpEncrypt(pgpTo, pgpSigner, inner)
+			xcheckf(ctx, err, "encrypting message")
+			xc.Header("Content-Type", fmt.Sprintf(`multipart/encrypted; protocol="application/pgp-encrypted"; boundary="%s"`, mp.Boundary()))
+			xc.Line()
+			hdr := textproto.MIMEHeader{}
+			hdr.Set("Content-Type", "application/pgp-encrypted")
+			hdr.Set("Content-Description", "PGP/MIME version identification")
+			vp, err := mp.CreatePart(hdr)
+			xcheckf(ctx, err, "adding version part")
+			_, err = vp.Write([]byte("Version: 1\r\n"))
+			xcheckf(ctx, err, "writing version part")
+			hdr = textproto.MIMEHeader{}
+			hdr.Set("Content-Type", `application/octet-stream; name="encrypted.asc"`)
+			hdr.Set("Content-Description", "OpenPGP encrypted message")
+			hdr.Set("Content-Disposition", `inline; filename="encrypted.asc"`)
+			ep, err := mp.CreatePart(hdr)
+			xcheckf(ctx, err, "adding encrypted part")
+			_, err = ep.Write(enc)
+			xcheckf(ctx, err, "writing encrypted part")
+		}
+		err := mp.Close()
+		xcheckf(ctx, err, "writing mime multipart")
+	}
 
-		textBody, ct, cte := xc.TextPart("plain", m.TextBody)
+	xaddTextPart := func(mp *multipart.Writer, subtype, text string) {
+		textBody, ct, cte := bc.TextPart(subtype, text)
 		textHdr := textproto.MIMEHeader{}
 		textHdr.Set("Content-Type", ct)
 		textHdr.Set("Content-Transfer-Encoding", cte)
@@ -813,6 +1236,90 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 		xcheckf(ctx, err, "adding text part to message")
 		_, err = textp.Write(textBody)
 		xcheckf(ctx, err, "writing text part")
+	}
+
+	// Write base64 data in lines.
+	xwriteBase64 := func(w io.Writer, base64Data []byte) {
+		for len(base64Data) > 0 {
+			line := base64Data
+			n := min(len(line), 76) // ../rfc/2045:1372
+			line, base64Data = base64Data[:n], base64Data[n:]
+			_, err := w.Write(line)
+			xcheckf(ctx, err, "writing attachment")
+			_, err = w.Write([]byte("\r\n"))
+			xcheckf(ctx, err, "writing attachment")
+		}
+	}
+
+	// Add a multipart part of subtype to mp, with the parts added by fill.
+	xaddMultipart := func(mp *multipart.Writer, subtype string, fill func(mp *multipart.Writer)) {
+		var buf bytes.Buffer
+		nmp := multipart.NewWriter(&buf)
+		fill(nmp)
+		err := nmp.Close()
+		xcheckf(ctx, err, "writing nested mime multipart")
+		hdr := textproto.MIMEHeader{}
+		hdr.Set("Content-Type", fmt.Sprintf(`multipart/%s; boundary="%s"`, subtype, nmp.Boundary()))
+		np, err := mp.CreatePart(hdr)
+		xcheckf(ctx, err, "adding multipart part to message")
+		_, err = np.Write(buf.Bytes())
+		xcheckf(ctx, err, "writing multipart part")
+	}
+
+	// Add the text and html versions of the message. With inline files, the html is in
+	// a multipart/related with the files.
+	xaddAlternativeParts := func(mp *multipart.Writer) {
+		xaddTextPart(mp, "plain", m.TextBody)
+		if len(m.InlineFiles) == 0 {
+			xaddTextPart(mp, "html", htmlBody)
+			return
+		}
+		xaddMultipart(mp, "related", func(rmp *multipart.Writer) {
+			xaddTextPart(rmp, "html", htmlBody)
+			for _, f := range m.InlineFiles {
+				if f.ContentID == "" || strings.ContainsAny(f.ContentID, "<> \t") {
+					xcheckuserf(ctx, fmt.Errorf("invalid content-id %q", f.ContentID), "checking inline file")
+				}
+				ct, data, err := parseDataURI(f.DataURI)
+				xcheckuserf(ctx, err, "parsing inline file")
+				if !strings.HasPrefix(ct, "image/") {
+					xcheckuserf(ctx, fmt.Errorf("content-type %q is not an image", ct), "checking inline file")
+				}
+				ihdr := textproto.MIMEHeader{}
+				if f.Filename != "" {
+					ct = mime.FormatMediaType(ct, map[string]string{"name": f.Filename})
+					ihdr.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": f.Filename}))
+				} else {
+					ihdr.Set("Content-Disposition", "inline")
+				}
+				ihdr.Set("Content-Type", ct)
+				ihdr.Set("Content-Transfer-Encoding", "base64")
+				ihdr.Set("Content-Id", "<"+f.ContentID+">")
+				ip, err := rmp.CreatePart(ihdr)
+				xcheckf(ctx, err, "adding inline part to message")
+				xwriteBase64(ip, []byte(data))
+			}
+		})
+	}
+
+	var draftAttachments []store.DraftAttachment
+	if m.DraftID > 0 {
+		xdbread(ctx, acc, func(tx *bstore.Tx) {
+			xdraftGet(ctx, tx, m.DraftID)
+			draftAttachments = xdraftAttachments(ctx, tx, m.DraftID)
+		})
+	}
+
+	if len(m.Attachments) > 0 || len(draftAttachments) > 0 || len(m.ForwardAttachments.Paths) > 0 || m.AttachMessageID > 0 {
+		mp := multipart.NewWriter(bc)
+		bc.Header("Content-Type", fmt.Sprintf(`multipart/mixed; boundary="%s"`, mp.Boundary()))
+		bc.Line()
+
+		if htmlBody == "" {
+			xaddTextPart(mp, "plain", m.TextBody)
+		} else {
+			xaddMultipart(mp, "alternative", xaddAlternativeParts)
+		}
 
 		xaddPart := func(ct, filename string) io.Writer {
 			ahdr := textproto.MIMEHeader{}
@@ -828,16 +1335,7 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 
 		xaddAttachmentBase64 := func(ct, filename string, base64Data []byte) {
 			ap := xaddPart(ct, filename)
-
-			for len(base64Data) > 0 {
-				line := base64Data
-				n := min(len(line), 76) // ../rfc/2045:1372
-				line, base64Data = base64Data[:n], base64Data[n:]
-				_, err := ap.Write(line)
-				xcheckf(ctx, err, "writing attachment")
-				_, err = ap.Write([]byte("\r\n"))
-				xcheckf(ctx, err, "writing attachment")
-			}
+			xwriteBase64(ap, base64Data)
 		}
 
 		xaddAttachment := func(ct, filename string, r io.Reader) {
@@ -850,35 +1348,23 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 		}
 
 		for _, a := range m.Attachments {
-			s := a.DataURI
-			if !strings.HasPrefix(s, "data:") {
-				xcheckuserf(ctx, errors.New("missing data: in datauri"), "parsing attachment")
-			}
-			s = s[len("data:"):]
-			t := strings.SplitN(s, ",", 2)
-			if len(t) != 2 {
-				xcheckuserf(ctx, errors.New("missing comma in datauri"), "parsing attachment")
-			}
-			if !strings.HasSuffix(t[0], "base64") {
-				xcheckuserf(ctx, errors.New("missing base64 in datauri"), "parsing attachment")
-			}
-			ct := strings.TrimSuffix(t[0], "base64")
-			ct = strings.TrimSuffix(ct, ";")
-			if ct == "" {
-				ct = "application/octet-stream"
-			}
+			ct, data, err := parseDataURI(a.DataURI)
+			xcheckuserf(ctx, err, "parsing attachment")
 			filename := a.Filename
 			if filename == "" {
 				filename = "unnamed.bin"
 			}
-			params := map[string]string{"name": filename}
-			ct = mime.FormatMediaType(ct, params)
+			// Parameters from the data URI are kept, e.g. "method" for text/calendar.
+			mt, params, err := mime.ParseMediaType(ct)
+			xcheckuserf(ctx, err, "parsing attachment content-type")
+			params["name"] = filename
+			ct = mime.FormatMediaType(mt, params)
 
-			// Ensure base64 is valid, then we'll write the original string.
-			_, err := io.Copy(io.Discard, base64.NewDecoder(base64.StdEncoding, strings.NewReader(t[1])))
-			xcheckuserf(ctx, err, "parsing attachment as base64")
+			xaddAttachmentBase64(ct, filename, []byte(data))
+		}
 
-			xaddAttachmentBase64(ct, filename, []byte(t[1]))
+		for _, a := range draftAttachments {
+			xaddAttachment(a.ContentType, a.Filename, bytes.NewReader(a.Data))
 		}
 
 		if len(m.ForwardAttachments.Paths) > 0 {
@@ -924,18 +1410,81 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 			})
 		}
 
+		if m.AttachMessageID > 0 {
+			var buf []byte
+			var subject string
+			acc.WithRLock(func() {
+				xdbread(ctx, acc, func(tx *bstore.Tx) {
+					am := xmessageID(ctx, tx, m.AttachMessageID)
+					subject = am.SubjectBase
+					msgr := acc.MessageReader(am)
+					defer func() {
+						err := msgr.Close()
+						log.Check(err, "closing message reader")
+					}()
+					buf, err = io.ReadAll(msgr)
+					xcheckf(ctx, err, "reading message to attach")
+				})
+			})
+
+			// Message parts cannot have base64 encoding. ../rfc/2046:1307
+			cte := "7bit"
+			if slices.ContainsFunc(buf, func(b byte) bool { return b >= 0x80 }) {
+				if bc.Require7bit {
+					xcheckuserf(ctx, errors.New("message to attach has 8bit data, cannot be signed"), "attaching message")
+				}
+				cte = "8bit"
+				xc.Has8bit = true
+			}
+			filename := strings.TrimSpace(subject)
+			if filename == "" {
+				filename = "message"
+			}
+			ahdr := textproto.MIMEHeader{}
+			ahdr.Set("Content-Type", "message/rfc822")
+			ahdr.Set("Content-Transfer-Encoding", cte)
+			ahdr.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename + ".eml"}))
+			ap, err := mp.CreatePart(ahdr)
+			xcheckf(ctx, err, "adding message part")
+			_, err = ap.Write(buf)
+			xcheckf(ctx, err, "writing message part")
+		}
+
 		err = mp.Close()
 		xcheckf(ctx, err, "writing mime multipart")
+	} else if htmlBody != "" {
+		mp := multipart.NewWriter(bc)
+		bc.Header("Content-Type", fmt.Sprintf(`multipart/alternative; boundary="%s"`, mp.Boundary()))
+		bc.Line()
+		xaddAlternativeParts(mp)
+		err := mp.Close()
+		xcheckf(ctx, err, "writing mime multipart")
 	} else {
-		textBody, ct, cte := xc.TextPart("plain", m.TextBody)
-		xc.Header("Content-Type", ct)
-		xc.Header("Content-Transfer-Encoding", cte)
-		xc.Line()
-		xc.Write([]byte(textBody))
+		textBody, ct, cte := bc.TextPart("plain", m.TextBody)
+		bc.Header("Content-Type", ct)
+		bc.Header("Content-Transfer-Encoding", cte)
+		bc.Line()
+		bc.Write([]byte(textBody))
+	}
+
+	if m.PGPSign || m.PGPEncrypt {
+		bc.Flush()
+		xaddPGP(ibuf.Bytes())
 	}
 
 	xc.Flush()
 
+	size, changed, err := acc.ApplyOutgoingFooter(log, fromAddr.Address, dataFile)
+	xcheckf(ctx, err, "adding outgoing footer")
+	if changed {
+		xc.Size = size
+	}
+	size, changed, err = acc.ApplyOutgoingHeaderRules(log, fromAddr.Address, dataFile)
+	xcheckf(ctx, err, "applying outgoing header rules")
+	if changed {
+		xc.Size = size
+	}
+
 	// Add DKIM-Signature headers.
 	var msgPrefix string
 	fd := fromAddr.Address.Domain
@@ -943,7 +1492,7 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 	if confDom.Disabled {
 		xcheckuserf(ctx, mox.ErrDomainDisabled, "checking domain")
 	}
-	selectors := mox.DKIMSelectors(confDom.DKIM)
+	selectors := mox.DKIMSelectorsMessage(log, confDom.DKIM, acc.Name, fromAddr.Address, dataFile)
 	if len(selectors) > 0 {
 		dkimHeaders, err := dkim.Sign(ctx, log.Logger, fromAddr.Address.Localpart, fd, selectors, smtputf8, dataFile)
 		if err != nil {
@@ -965,6 +1514,21 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 	}
 	qml := make([]queue.Msg, len(recipients))
 	now := time.Now()
+
+	// Hold back delivery for a short while so sending can be undone.
+	var undoUntil *time.Time
+	if m.FutureRelease == nil {
+		settings := store.Settings{ID: 1}
+		xdbread(ctx, acc, func(tx *bstore.Tx) {
+			err := tx.Get(&settings)
+			xcheckf(ctx, err, "get settings")
+		})
+		if settings.UndoSendSeconds > 0 {
+			t := now.Add(time.Duration(settings.UndoSendSeconds) * time.Second)
+			undoUntil = &t
+		}
+	}
+
 	for i, rcpt := range recipients {
 		fp := fromPath
 		var fromID string
@@ -996,6 +1560,8 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 			qm.NextAttempt = *m.FutureRelease
 			qm.FutureReleaseRequest = "until;" + m.FutureRelease.Format(time.RFC3339)
 			// todo: possibly add a header to the message stored in the Sent mailbox to indicate it was scheduled for later delivery.
+		} else if undoUntil != nil {
+			qm.NextAttempt = *undoUntil
 		}
 		qm.FromID = fromID
 		// no qm.Extra from webmail
@@ -1008,6 +1574,13 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 	xcheckf(ctx, err, "adding messages to the delivery queue")
 	metricSubmission.WithLabelValues("ok").Inc()
 
+	if undoUntil != nil {
+		for _, qm := range qml {
+			result.QueueMsgIDs = append(result.QueueMsgIDs, qm.ID)
+		}
+		result.UndoUntil = undoUntil
+	}
+
 	var modseq store.ModSeq // Only set if needed.
 
 	// We have committed to sending the message. We want to follow through
@@ -1043,6 +1616,9 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 				nchanges := xops.MessageDeleteTx(ctx, log, tx, acc, []int64{m.DraftMessageID}, &modseq)
 				changes = append(changes, nchanges...)
 			}
+			if m.DraftID > 0 {
+				xdraftRemove(ctx, tx, m.DraftID)
+			}
 
 			if m.ResponseMessageID > 0 {
 				rm := xmessageID(ctx, tx, m.ResponseMessageID)
@@ -1142,6 +1718,7 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 			}
 			xcheckf(ctx, err, "message submitted to queue, appending message to Sent mailbox")
 			newIDs = append(newIDs, sentm.ID)
+			result.SentMessageID = sentm.ID
 
 			err = tx.Update(&sentmb)
 			xcheckf(ctx, err, "updating sent mailbox for counts")
@@ -1152,39 +1729,373 @@ func (w Webmail) MessageSubmit(ctx context.Context, m SubmitMessage) {
 
 		store.BroadcastChanges(acc, changes)
 	})
+	return result
 }
 
-// MessageMove moves messages to another mailbox. If the message is already in
-// the mailbox an error is returned.
-func (Webmail) MessageMove(ctx context.Context, messageIDs []int64, mailboxID int64) {
+// MessageSubmitUndo cancels delivery of a message submitted with MessageSubmit
+// that is still held back, see SubmitResult. The copy of the message in the Sent
+// mailbox, if any, is moved to the Drafts mailbox and marked as draft, so it can
+// be edited and sent again.
+func (Webmail) MessageSubmitUndo(ctx context.Context, queueMsgIDs []int64, sentMessageID int64) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	acc := reqInfo.Account
 	log := reqInfo.Log
 
-	xops.MessageMove(ctx, log, acc, messageIDs, "", mailboxID)
-}
-
-var xops = webops.XOps{
-	DBWrite:    xdbwrite,
-	Checkf:     xcheckf,
-	Checkuserf: xcheckuserf,
-}
-
-// MessageDelete permanently deletes messages, without moving them to the Trash mailbox.
-func (Webmail) MessageDelete(ctx context.Context, messageIDs []int64) {
-	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
-	acc := reqInfo.Account
-	log := reqInfo.Log
+	if len(queueMsgIDs) == 0 {
+		xcheckuserf(ctx, errors.New("no messages"), "canceling delivery")
+	}
+	err := queue.Cancel(ctx, log, acc.Name, queueMsgIDs)
+	if errors.Is(err, queue.ErrCancelStarted) {
+		xcheckuserf(ctx, err, "canceling delivery")
+	}
+	xcheckf(ctx, err, "canceling delivery")
 
-	if len(messageIDs) == 0 {
+	if sentMessageID == 0 {
 		return
 	}
 
-	xops.MessageDelete(ctx, log, acc, messageIDs)
+	var draftsID int64
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		mb, err := bstore.QueryTx[store.Mailbox](tx).FilterEqual("Expunged", false).FilterEqual("Draft", true).Get()
+		if err == bstore.ErrAbsent {
+			return
+		}
+		xcheckf(ctx, err, "looking up drafts mailbox")
+		draftsID = mb.ID
+	})
+	// Without Drafts mailbox, the message stays in the Sent mailbox.
+	if draftsID != 0 {
+		xops.MessageMove(ctx, log, acc, []int64{sentMessageID}, "", draftsID)
+	}
+	xops.MessageFlagsAdd(ctx, log, acc, []int64{sentMessageID}, []string{`\Draft`})
 }
 
-// FlagsAdd adds flags, either system flags like \Seen or custom keywords. The
-// flags should be lower-case, but will be converted and verified.
+// MessagePGP verifies the signature of an OpenPGP signed message, or decrypts an
+// OpenPGP encrypted message and verifies the signature inside it, if any. The
+// private keys of the account are decrypted with passphrase, it can be empty for
+// keys without passphrase. Signatures are verified with the keys stored in the
+// account, and with keys from the Web Key Directory of the From address.
+func (Webmail) MessagePGP(ctx context.Context, msgID int64, passphrase string) (result PGPResult) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+	acc := reqInfo.Account
+
+	var m store.Message
+	var keys []store.PGPKey
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m = xmessageID(ctx, tx, msgID)
+		var err error
+		keys, err = bstore.QueryTx[store.PGPKey](tx).List()
+		xcheckf(ctx, err, "listing pgp keys")
+	})
+
+	msgr := acc.MessageReader(m)
+	defer func() {
+		err := msgr.Close()
+		log.Check(err, "closing message reader")
+	}()
+	p, err := m.LoadPart(msgr)
+	xcheckf(ctx, err, "load parsed message")
+
+	v := pgpVerifier{ctx: ctx, log: log, ring: pgpKeyRing(log, keys, passphrase)}
+	if p.Envelope != nil && len(p.Envelope.From) == 1 {
+		from := p.Envelope.From[0]
+		lp, err := smtp.ParseLocalpart(from.User)
+		if err == nil {
+			dom, err := dns.ParseDomain(from.Host)
+			if err == nil {
+				v.from = smtp.NewAddress(lp, dom)
+			}
+		}
+	}
+
+	switch {
+	case p.MediaType == "MULTIPART" && p.MediaSubType == "SIGNED":
+		signed, sig, err := pgpSignedParts(&p, msgr)
+		xcheckuserf(ctx, err, "reading signed message")
+		v.verifyDetached(&result, signed, sig)
+
+	case p.MediaType == "MULTIPART" && p.MediaSubType == "ENCRYPTED":
+		if !strings.EqualFold(p.ContentTypeParams["protocol"], "application/pgp-encrypted") || len(p.Parts) != 2 {
+			xcheckuserf(ctx, errors.New("not an openpgp encrypted message"), "decrypting message")
+		}
+		data, md, err := pgpDecrypt(v.ring, p.Parts[1].Reader())
+		if err != nil && errors.Is(err, pgperrors.ErrKeyIncorrect) {
+			err = errors.New("message not encrypted to a private key of the account")
+		}
+		xcheckuserf(ctx, err, "decrypting message")
+		if md.IsSigned && md.SignedBy == nil {
+			// Try again with the keys of the sender from the Web Key Directory.
+			if l := v.wkdLookup(); len(l) > 0 {
+				data, md, err = pgpDecrypt(append(slices.Clone(v.ring), l...), p.Parts[1].Reader())
+				xcheckuserf(ctx, err, "decrypting message")
+			}
+		}
+		result.Decrypted = true
+		if md.IsSigned {
+			var signer *openpgp.Entity
+			if md.SignedBy != nil {
+				signer = md.SignedBy.Entity
+			}
+			err := md.SignatureError
+			if md.SignedBy == nil {
+				err = pgperrors.ErrUnknownIssuer
+			}
+			v.result(&result, md.SignedByKeyId, signer, err)
+		}
+
+		// The decrypted data is a MIME entity. It can be multipart/signed, with the
+		// signature separate from the encryption.
+		data = pgpCRLF(data)
+		dp, err := message.Parse(log.Logger, false, bytes.NewReader(data))
+		if err == nil {
+			err = dp.Walk(log.Logger, nil)
+		}
+		xcheckuserf(ctx, err, "parsing decrypted message")
+		if !md.IsSigned && dp.MediaType == "MULTIPART" && dp.MediaSubType == "SIGNED" {
+			signed, sig, err := pgpSignedParts(&dp, bytes.NewReader(data))
+			xcheckuserf(ctx, err, "reading signed message")
+			v.verifyDetached(&result, signed, sig)
+		}
+		result.Texts, err = pgpTexts(&dp)
+		xcheckuserf(ctx, err, "reading decrypted message")
+
+	default:
+		xcheckuserf(ctx, errors.New("not an openpgp signed or encrypted message"), "checking message")
+	}
+	return
+}
+
+// CalendarReply responds to the meeting invitation in a message, with status
+// ACCEPTED, TENTATIVE or DECLINED. The reply is sent to the organizer, from the
+// first attendee address of the account.
+func (w Webmail) CalendarReply(ctx context.Context, msgID int64, status string) SubmitResult {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+	acc := reqInfo.Account
+
+	var verb string
+	switch status {
+	case "ACCEPTED":
+		verb = "Accepted"
+	case "TENTATIVE":
+		verb = "Tentatively accepted"
+	case "DECLINED":
+		verb = "Declined"
+	default:
+		xcheckuserf(ctx, fmt.Errorf("unknown status %q", status), "checking status")
+	}
+
+	var m store.Message
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m = xmessageID(ctx, tx, msgID)
+	})
+
+	state := msgState{acc: acc, m: m}
+	defer state.clear()
+	pm, err := parsedMessage(log, &m, &state, false, true, false)
+	xcheckf(ctx, err, "parsing message")
+	inv := pm.calendar
+	if inv == nil {
+		xcheckuserf(ctx, errors.New("no calendar event in message"), "looking up invitation")
+	} else if inv.Method != "REQUEST" {
+		xcheckuserf(ctx, fmt.Errorf("calendar message with method %q is not an invitation", inv.Method), "looking up invitation")
+	}
+	organizer, err := smtp.ParseAddress(inv.Organizer.Address)
+	xcheckuserf(ctx, err, "parsing organizer address")
+
+	var from smtp.Address
+	for _, a := range inv.Attendees {
+		addr, err := smtp.ParseAddress(a.Address)
+		if err != nil {
+			continue
+		}
+		if ok, _ := mox.AllowMsgFrom(acc.Name, addr); ok {
+			from = addr
+			break
+		}
+	}
+	if from.IsZero() {
+		xcheckuserf(ctx, errors.New("none of the attendees is an address of the account"), "looking up attendee")
+	}
+
+	ap := state.part
+	for _, i := range inv.Path {
+		ap = &ap.Parts[i]
+	}
+	_, cal, err := calendarInvite(ap, inv.Path)
+	xcheckf(ctx, err, "parsing calendar")
+	ics, err := calendarReply(cal, from.String(), status, time.Now())
+	xcheckf(ctx, err, "composing reply")
+
+	sm := SubmitMessage{
+		From:              from.String(),
+		To:                []string{organizer.String()},
+		Subject:           verb + ": " + inv.Summary,
+		TextBody:          fmt.Sprintf("%s has %s the invitation.\n", from, strings.ToLower(verb)),
+		Attachments:       []File{{Filename: "reply.ics", DataURI: "data:text/calendar;method=REPLY;charset=utf-8;base64," + base64.StdEncoding.EncodeToString(ics)}},
+		ResponseMessageID: msgID,
+	}
+	return w.MessageSubmit(ctx, sm)
+}
+
+// MessageUnsubscribe unsubscribes from the mailing list of a message with a
+// List-Unsubscribe header. Method "oneclick" makes an HTTPS POST request to the
+// URL from the header, RFC 8058. Method "mailto" sends an unsubscribe message to
+// the address from the header, from the address of the account the message was
+// sent to. The request is added to the unsubscribe history of the sender, also
+// when a one-click request fails.
+func (w Webmail) MessageUnsubscribe(ctx context.Context, msgID int64, method string) store.Unsubscribe {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+	acc := reqInfo.Account
+
+	var m store.Message
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m = xmessageID(ctx, tx, msgID)
+	})
+
+	state := msgState{acc: acc, m: m}
+	defer state.clear()
+	pm, err := parsedMessage(log, &m, &state, false, true, true)
+	xcheckf(ctx, err, "parsing message")
+	unsub := pm.unsubscribe
+	if unsub == nil {
+		xcheckuserf(ctx, errors.New("message has no list-unsubscribe header"), "looking up unsubscribe methods")
+	}
+	if len(pm.envelope.From) == 0 {
+		xcheckuserf(ctx, errors.New("message has no from address"), "looking up sender")
+	}
+	from := pm.envelope.From[0]
+	listID, _ := parseListID(textproto.MIMEHeader(pm.Headers).Get("List-Id"))
+
+	u := store.Unsubscribe{
+		FromAddress: strings.ToLower(smtp.NewAddress(smtp.Localpart(from.User), from.Domain).String()),
+		ListID:      listID,
+		MessageID:   m.ID,
+		Method:      method,
+	}
+
+	switch method {
+	case "oneclick":
+		if !unsub.OneClick {
+			xcheckuserf(ctx, errors.New("message does not support one-click unsubscribe"), "unsubscribing")
+		}
+		u.Target = unsub.URL
+		err := unsubscribeOneClick(ctx, unsub.URL)
+		if err != nil {
+			log.Debugx("one-click unsubscribe", err, slog.String("url", unsub.URL))
+			u.Error = err.Error()
+		}
+	case "mailto":
+		if unsub.Mailto == "" {
+			xcheckuserf(ctx, errors.New("message has no mailto unsubscribe address"), "unsubscribing")
+		}
+		to, subject, body, err := unsubscribeMailto(unsub.Mailto)
+		xcheckuserf(ctx, err, "parsing unsubscribe address")
+		u.Target = to.String()
+
+		// Send from the address the message was delivered to.
+		var rcpt smtp.Address
+		for _, a := range append(append([]MessageAddress{}, pm.envelope.To...), pm.envelope.CC...) {
+			addr := smtp.NewAddress(smtp.Localpart(a.User), a.Domain)
+			if ok, _ := mox.AllowMsgFrom(acc.Name, addr); ok {
+				rcpt = addr
+				break
+			}
+		}
+		if rcpt.IsZero() {
+			xcheckuserf(ctx, errors.New("none of the recipients is an address of the account"), "looking up address to send from")
+		}
+		sm := SubmitMessage{
+			From:     rcpt.String(),
+			To:       []string{to.String()},
+			Subject:  subject,
+			TextBody: body + "\n",
+		}
+		w.MessageSubmit(ctx, sm)
+	default:
+		xcheckuserf(ctx, fmt.Errorf("unknown method %q", method), "checking method")
+	}
+
+	err = acc.DB.Insert(ctx, &u)
+	xcheckf(ctx, err, "adding unsubscribe to history")
+	if u.Error != "" {
+		xcheckuserf(ctx, errors.New(u.Error), "unsubscribing")
+	}
+	return u
+}
+
+// UnsubscribeHistory returns the unsubscribe requests made for messages from an
+// address, most recent first.
+func (Webmail) UnsubscribeHistory(ctx context.Context, fromAddress string) []store.Unsubscribe {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	var l []store.Unsubscribe
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		var err error
+		l, err = bstore.QueryTx[store.Unsubscribe](tx).FilterNonzero(store.Unsubscribe{FromAddress: strings.ToLower(fromAddress)}).SortDesc("Time").List()
+		xcheckf(ctx, err, "listing unsubscribe history")
+	})
+	return l
+}
+
+// SenderBlock adds the message From address of a message, or its domain if
+// domain is set, to the sender lists of the account with action "reject",
+// causing future messages from the sender to be rejected during SMTP. The
+// pattern of the added entry is returned.
+func (Webmail) SenderBlock(ctx context.Context, msgID int64, domain bool) string {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	var m store.Message
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m = xmessageID(ctx, tx, msgID)
+	})
+	pattern := store.SenderAddress(m.MsgFromLocalpart, m.MsgFromDomain)
+	if pattern == "" {
+		xcheckuserf(ctx, errors.New("message has no from address"), "blocking sender")
+	} else if domain {
+		pattern = m.MsgFromDomain
+	}
+	e, err := acc.SenderListSave(log, pattern, store.SenderListReject)
+	xcheckuserf(ctx, err, "blocking sender")
+	return e.Pattern
+}
+
+// MessageMove moves messages to another mailbox. If the message is already in
+// the mailbox an error is returned.
+func (Webmail) MessageMove(ctx context.Context, messageIDs []int64, mailboxID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	xops.MessageMove(ctx, log, acc, messageIDs, "", mailboxID)
+}
+
+var xops = webops.XOps{
+	DBWrite:    xdbwrite,
+	Checkf:     xcheckf,
+	Checkuserf: xcheckuserf,
+}
+
+// MessageDelete permanently deletes messages, without moving them to the Trash mailbox.
+func (Webmail) MessageDelete(ctx context.Context, messageIDs []int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	if len(messageIDs) == 0 {
+		return
+	}
+
+	xops.MessageDelete(ctx, log, acc, messageIDs)
+}
+
+// FlagsAdd adds flags, either system flags like \Seen or custom keywords. The
+// flags should be lower-case, but will be converted and verified.
 func (Webmail) FlagsAdd(ctx context.Context, messageIDs []int64, flaglist []string) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	acc := reqInfo.Account
@@ -1212,6 +2123,105 @@ func (Webmail) MailboxesMarkRead(ctx context.Context, mailboxIDs []int64) {
 	xops.MailboxesMarkRead(ctx, log, acc, mailboxIDs)
 }
 
+// BulkOp is an operation applied to all messages matching a query.
+type BulkOp string
+
+const (
+	BulkMarkRead   BulkOp = "markread"
+	BulkMarkUnread BulkOp = "markunread"
+	BulkMove       BulkOp = "move"  // To BulkAction.MailboxID.
+	BulkLabel      BulkOp = "label" // Add BulkAction.Label.
+	BulkDelete     BulkOp = "delete"
+)
+
+// BulkAction is an operation with its parameters, for MessageBulk.
+type BulkAction struct {
+	Op        BulkOp
+	MailboxID int64
+	Label     string
+}
+
+// Number of messages changed per transaction by MessageBulk.
+const bulkBatchSize = 500
+
+// MessageBulk applies an action to all messages matching the filters of the
+// query, e.g. the current view of the client, without the client having to
+// fetch and send all message IDs. Messages are changed in batches, each in its
+// own transaction. If sseID is nonzero, EventBulkProgress events are sent on the
+// SSE connection after each batch. Deleting is permanent. The number of messages
+// that were changed is returned.
+func (Webmail) MessageBulk(ctx context.Context, sseID int64, query Query, action BulkAction) int {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	var flags []string
+	switch action.Op {
+	case BulkMarkRead, BulkMarkUnread:
+		flags = []string{`\seen`}
+	case BulkLabel:
+		if action.Label == "" {
+			xcheckuserf(ctx, errors.New("label required"), "checking action")
+		}
+		flags = []string{action.Label}
+	case BulkMove, BulkDelete:
+	default:
+		xcheckuserf(ctx, fmt.Errorf("unknown operation %q", action.Op), "checking action")
+	}
+
+	var sse sse
+	if sseID != 0 {
+		var ok bool
+		sse, ok = sseGet(sseID, acc.Name)
+		if !ok {
+			xcheckuserf(ctx, errors.New("unknown sseid"), "looking up connection")
+		}
+	}
+
+	accConf, _ := acc.Conf()
+	var ids []int64
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		if action.Op == BulkMove {
+			xmailboxID(ctx, tx, action.MailboxID)
+		}
+		err := matchingMessages(ctx, log, acc, tx, accConf.RejectsMailbox, query, func(m store.Message) error {
+			// Skip messages that wouldn't change. Moving to the same mailbox is an error.
+			if action.Op == BulkMove && m.MailboxID == action.MailboxID || action.Op == BulkMarkRead && m.Seen || action.Op == BulkMarkUnread && !m.Seen {
+				return nil
+			}
+			ids = append(ids, m.ID)
+			return nil
+		})
+		xcheckf(ctx, err, "listing matching messages")
+	})
+
+	for i := 0; i < len(ids); i += bulkBatchSize {
+		err := ctx.Err()
+		xcheckf(ctx, err, "applying bulk action")
+
+		batch := ids[i:min(i+bulkBatchSize, len(ids))]
+		switch action.Op {
+		case BulkMarkRead, BulkLabel:
+			xops.MessageFlagsAdd(ctx, log, acc, batch, flags)
+		case BulkMarkUnread:
+			xops.MessageFlagsClear(ctx, log, acc, batch, flags)
+		case BulkMove:
+			xops.MessageMove(ctx, log, acc, batch, "", action.MailboxID)
+		case BulkDelete:
+			xops.MessageDelete(ctx, log, acc, batch)
+		}
+
+		if sse.Bulk != nil {
+			// Progress is informational, we don't wait for a slow connection.
+			select {
+			case sse.Bulk <- EventBulkProgress{action.Op, i + len(batch), len(ids)}:
+			default:
+			}
+		}
+	}
+	return len(ids)
+}
+
 // MailboxCreate creates a new mailbox.
 func (Webmail) MailboxCreate(ctx context.Context, name string) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
@@ -1337,8 +2347,9 @@ func (Webmail) MailboxRename(ctx context.Context, mailboxID int64, newName strin
 }
 
 // CompleteRecipient returns autocomplete matches for a recipient, returning the
-// matches, most recently used first, and whether this is the full list and further
-// requests for longer prefixes aren't necessary.
+// matches, most recently used first followed by matching contacts from the
+// address books, and whether this is the full list and further requests for
+// longer prefixes aren't necessary.
 func (Webmail) CompleteRecipient(ctx context.Context, search string) ([]string, bool) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	acc := reqInfo.Account
@@ -1358,7 +2369,7 @@ func (Webmail) CompleteRecipient(ctx context.Context, search string) ([]string,
 			q := bstore.QueryTx[store.Recipient](tx)
 			q.SortDesc("Sent")
 			err := q.ForEach(func(r store.Recipient) error {
-				k := key{r.Localpart, r.Domain}
+				k := key{strings.ToLower(r.Localpart), strings.ToLower(r.Domain)}
 				if seen[k] {
 					return nil
 				}
@@ -1411,6 +2422,37 @@ func (Webmail) CompleteRecipient(ctx context.Context, search string) ([]string,
 				return nil
 			})
 			xcheckf(ctx, err, "listing recipients")
+			if !all {
+				return
+			}
+
+			// Add contacts, matching on name and email address.
+			err = bstore.QueryTx[store.Contact](tx).SortAsc("FullName").ForEach(func(c store.Contact) error {
+				nameMatch := strings.Contains(strings.ToLower(c.FullName), search)
+				for _, email := range c.Emails {
+					i := strings.LastIndex(email, "@")
+					if i < 0 {
+						continue
+					}
+					k := key{email[:i], email[i+1:]}
+					if seen[k] || !nameMatch && !strings.Contains(email, search) {
+						continue
+					}
+					if len(matches) >= 20 {
+						all = false
+						return bstore.StopForEach
+					}
+					name := c.FullName
+					if strings.EqualFold(name, email) {
+						// Collected contacts without name have the address as name.
+						name = ""
+					}
+					matches = append(matches, addressString(message.Address{Name: name, User: email[:i], Host: email[i+1:]}, false))
+					seen[k] = true
+				}
+				return nil
+			})
+			xcheckf(ctx, err, "listing contacts")
 		})
 	})
 	return matches, all
@@ -1615,6 +2657,171 @@ func (Webmail) ThreadMute(ctx context.Context, messageIDs []int64, mute bool) {
 	})
 }
 
+// ThreadSummary is a conversation: the messages with the same ThreadID, across
+// all mailboxes.
+type ThreadSummary struct {
+	ThreadID     int64
+	MessageIDs   []int64          // Ordered by received time, oldest first.
+	MailboxIDs   []int64          // Mailboxes with messages of the thread.
+	Unread       int              // Number of messages without \Seen flag.
+	Participants []MessageAddress // Unique From addresses, in order of first appearance.
+	Subject      string           // Of the latest message.
+	Snippet      string           // Preview of the text of the latest message.
+	Latest       time.Time        // Received time of the latest message.
+	Muted        bool             // Whether all messages in the thread are muted.
+	Collapsed    bool             // Whether all messages in the thread are collapsed.
+}
+
+// Threads returns conversations that have at least one message in the mailbox, or
+// in any mailbox if mailboxID is 0. Threads are ordered by their most recent
+// message, newest first. The first offset threads are skipped, at most limit
+// threads are returned.
+func (Webmail) Threads(ctx context.Context, mailboxID int64, offset, limit int) []ThreadSummary {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	if offset < 0 || limit <= 0 || limit > 1000 {
+		xcheckuserf(ctx, errors.New("offset must be >= 0, limit between 1 and 1000"), "checking parameters")
+	}
+
+	newPreviews := map[int64]string{}
+	defer storeNewPreviews(ctx, log, acc, newPreviews)
+
+	var threads []ThreadSummary
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		if mailboxID != 0 {
+			xmailboxID(ctx, tx, mailboxID)
+		}
+
+		// Find the threads with most recent messages.
+		latest := map[int64]time.Time{}
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterEqual("Expunged", false)
+		if mailboxID != 0 {
+			q.FilterNonzero(store.Message{MailboxID: mailboxID})
+		}
+		q.FilterGreater("ThreadID", int64(0))
+		err := q.ForEach(func(m store.Message) error {
+			if m.Received.After(latest[m.ThreadID]) {
+				latest[m.ThreadID] = m.Received
+			}
+			return nil
+		})
+		xcheckf(ctx, err, "listing messages")
+		threadIDs := slices.Collect(maps.Keys(latest))
+		slices.SortFunc(threadIDs, func(a, b int64) int {
+			if c := latest[b].Compare(latest[a]); c != 0 {
+				return c
+			}
+			return cmp.Compare(b, a)
+		})
+		threadIDs = threadIDs[min(offset, len(threadIDs)):]
+		threadIDs = threadIDs[:min(limit, len(threadIDs))]
+		if len(threadIDs) == 0 {
+			return
+		}
+
+		// Gather all messages of the threads, in any mailbox.
+		byThread := map[int64][]store.Message{}
+		qt := bstore.QueryTx[store.Message](tx)
+		qt.FilterEqual("Expunged", false)
+		qt.FilterEqual("ThreadID", slicesAny(threadIDs)...)
+		qt.SortAsc("Received", "ID")
+		err = qt.ForEach(func(m store.Message) error {
+			byThread[m.ThreadID] = append(byThread[m.ThreadID], m)
+			return nil
+		})
+		xcheckf(ctx, err, "listing messages in threads")
+
+		for _, threadID := range threadIDs {
+			ts := ThreadSummary{ThreadID: threadID, Muted: true, Collapsed: true}
+			seen := map[string]bool{}
+			for _, m := range byThread[threadID] {
+				ts.MessageIDs = append(ts.MessageIDs, m.ID)
+				if !slices.Contains(ts.MailboxIDs, m.MailboxID) {
+					ts.MailboxIDs = append(ts.MailboxIDs, m.MailboxID)
+				}
+				if !m.Seen {
+					ts.Unread++
+				}
+				ts.Muted = ts.Muted && m.ThreadMuted
+				ts.Collapsed = ts.Collapsed && m.ThreadCollapsed
+
+				state := msgState{acc: acc, log: log, newPreviews: newPreviews}
+				mi, err := messageItem(log, m, &state, nil)
+				state.clear()
+				xcheckf(ctx, err, "parsing message")
+				for _, a := range mi.Envelope.From {
+					k := strings.ToLower(a.User + "@" + a.Domain.ASCII)
+					if !seen[k] {
+						seen[k] = true
+						ts.Participants = append(ts.Participants, a)
+					}
+				}
+				ts.Subject = mi.Envelope.Subject
+				if mi.Message.Preview != nil {
+					ts.Snippet = *mi.Message.Preview
+				}
+				ts.Latest = m.Received
+			}
+			threads = append(threads, ts)
+		}
+	})
+	return threads
+}
+
+// ThreadsCollapse sets the collapsed state of all messages in the threads, across
+// all mailboxes.
+func (Webmail) ThreadsCollapse(ctx context.Context, threadIDs []int64, collapse bool) {
+	xthreadsUpdate(ctx, threadIDs, func(m store.Message) bool {
+		return m.ThreadCollapsed != collapse
+	}, map[string]any{"ThreadCollapsed": collapse})
+}
+
+// ThreadsMute sets the muted state of all messages in the threads, across all
+// mailboxes. If threads are muted, they are also marked collapsed.
+func (Webmail) ThreadsMute(ctx context.Context, threadIDs []int64, mute bool) {
+	fields := map[string]any{"ThreadMuted": mute}
+	if mute {
+		fields["ThreadCollapsed"] = true
+	}
+	xthreadsUpdate(ctx, threadIDs, func(m store.Message) bool {
+		return m.ThreadMuted != mute || mute && !m.ThreadCollapsed
+	}, fields)
+}
+
+// xthreadsUpdate updates fields of the messages in the threads for which
+// needUpdate returns true, and broadcasts the changes.
+func xthreadsUpdate(ctx context.Context, threadIDs []int64, needUpdate func(m store.Message) bool, fields map[string]any) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	if len(threadIDs) == 0 {
+		xcheckuserf(ctx, errors.New("no threads"), "updating threads")
+	}
+
+	acc.WithWLock(func() {
+		var changes []store.Change
+		xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+			var updated []store.Message
+			q := bstore.QueryTx[store.Message](tx)
+			q.FilterEqual("Expunged", false)
+			q.FilterEqual("ThreadID", slicesAny(threadIDs)...)
+			q.FilterFn(needUpdate)
+			q.Gather(&updated)
+			q.SortAsc("ID") // Consistent order for testing.
+			_, err := q.UpdateFields(fields)
+			xcheckf(ctx, err, "updating threads in database")
+
+			for _, m := range updated {
+				changes = append(changes, m.ChangeThread())
+			}
+		})
+		store.BroadcastChanges(acc, changes)
+	})
+}
+
 // SecurityResult indicates whether a security feature is supported.
 type SecurityResult string
 
@@ -1717,7 +2924,7 @@ func recipientSecurity(ctx context.Context, log mlog.Log, resolver dns.Resolver,
 		defer logPanic(ctx)
 		defer wg.Done()
 
-		_, origNextHopAuthentic, expandedNextHopAuthentic, _, hosts, _, err := smtpclient.GatherDestinations(ctx, log.Logger, resolver, dns.IPDomain{Domain: addr.Domain})
+		_, origNextHopAuthentic, expandedNextHopAuthentic, _, hosts, _, _, err := smtpclient.GatherDestinations(ctx, log.Logger, resolver, dns.IPDomain{Domain: addr.Domain})
 		if err != nil {
 			rs.DNSSEC = SecurityResultError
 			return
@@ -1809,27 +3016,236 @@ func (Webmail) SettingsSave(ctx context.Context, settings store.Settings) {
 	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
 	acc := reqInfo.Account
 
-	settings.ID = 1
-	err := acc.DB.Update(ctx, &settings)
-	xcheckf(ctx, err, "save settings")
+	if settings.UndoSendSeconds != 0 && (settings.UndoSendSeconds < 5 || settings.UndoSendSeconds > 30) {
+		xcheckuserf(ctx, errors.New("must be zero, or between 5 and 30"), "checking seconds to hold messages for undoing send")
+	}
+	err := checkHTMLPolicyNames(settings.HTMLAllowedTags, true)
+	xcheckuserf(ctx, err, "checking allowed html tags")
+	err = checkHTMLPolicyNames(settings.HTMLAllowedAttributes, false)
+	xcheckuserf(ctx, err, "checking allowed html attributes")
+	for i, sender := range settings.NotifySenders {
+		sender = strings.ToLower(strings.TrimSpace(sender))
+		t := strings.Split(sender, "@")
+		if len(t) != 2 || t[1] == "" {
+			xcheckuserf(ctx, fmt.Errorf("invalid sender %q, must be email address or domain starting with @", sender), "checking notification senders")
+		}
+		settings.NotifySenders[i] = sender
+	}
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		// Labels are managed through the Label* calls, which also change messages.
+		cur := store.Settings{ID: 1}
+		err := tx.Get(&cur)
+		xcheckf(ctx, err, "get settings")
+		settings.Labels = cur.Labels
+
+		settings.ID = 1
+		err = tx.Update(&settings)
+		xcheckf(ctx, err, "save settings")
+	})
 }
 
-func (Webmail) RulesetSuggestMove(ctx context.Context, msgID, mbSrcID, mbDstID int64) (listID string, msgFrom string, isRemove bool, rcptTo string, ruleset *config.Ruleset) {
-	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
-	acc := reqInfo.Account
-	log := reqInfo.Log
+// SharedTemplate is a message template shared by a domain the account has an
+// address in, configured by the admin.
+type SharedTemplate struct {
+	Domain  string // Domain name, unicode.
+	Name    string
+	Subject string
+	Text    string
+}
 
-	xdbread(ctx, acc, func(tx *bstore.Tx) {
-		m := xmessageID(ctx, tx, msgID)
-		mbSrc := xmailboxID(ctx, tx, mbSrcID)
-		mbDst := xmailboxID(ctx, tx, mbDstID)
+// TemplateRef references a template: an account template by ID, or a template
+// shared by a domain by its domain and name.
+type TemplateRef struct {
+	ID     int64
+	Domain string // Unicode.
+	Name   string
+}
 
-		if m.RcptToLocalpart == "" && m.RcptToDomain == "" {
-			return
-		}
-		rcptTo = m.RcptToLocalpart.String() + "@" + m.RcptToDomain
+// ComposeTemplate is a template with placeholders replaced, for composing a
+// message.
+type ComposeTemplate struct {
+	Subject     string // If empty, the subject should be left as is.
+	Text        string
+	Attachments []File
+}
 
-		conf, _ := acc.Conf()
+// Maximum total size of data URIs of the attachments of a template.
+const templateAttachmentsMax = 10 * 1024 * 1024
+
+// xsharedTemplates returns the message templates of domains the account has an
+// address in.
+func xsharedTemplates(ctx context.Context, accConf config.Account) []SharedTemplate {
+	domains := map[dns.Domain]bool{accConf.DNSDomain: true}
+	for a := range accConf.Destinations {
+		if strings.HasPrefix(a, "@") {
+			dom, err := dns.ParseDomain(a[1:])
+			xcheckf(ctx, err, "parsing destination address for account")
+			domains[dom] = true
+		} else {
+			addr, err := smtp.ParseAddress(a)
+			xcheckf(ctx, err, "parsing destination address for account")
+			domains[addr.Domain] = true
+		}
+	}
+
+	var l []SharedTemplate
+	for dom := range domains {
+		domConf, ok := mox.Conf.Domain(dom)
+		if !ok {
+			continue
+		}
+		for _, t := range domConf.MessageTemplates {
+			l = append(l, SharedTemplate{dom.Name(), t.Name, t.Subject, strings.Join(t.Text, "\n")})
+		}
+	}
+	slices.SortFunc(l, func(a, b SharedTemplate) int {
+		return cmp.Or(cmp.Compare(a.Domain, b.Domain), cmp.Compare(a.Name, b.Name))
+	})
+	return l
+}
+
+// Templates returns the message templates of the account, and the templates
+// shared by domains the account has an address in.
+func (Webmail) Templates(ctx context.Context) (templates []store.Template, shared []SharedTemplate) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	var err error
+	templates, err = bstore.QueryDB[store.Template](ctx, acc.DB).SortAsc("Name").List()
+	xcheckf(ctx, err, "listing templates")
+
+	accConf, _ := acc.Conf()
+	shared = xsharedTemplates(ctx, accConf)
+	return
+}
+
+// TemplateSave adds a template to the account if its ID is zero, and otherwise
+// updates the existing template. The saved template is returned.
+func (Webmail) TemplateSave(ctx context.Context, t store.Template) store.Template {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		xcheckuserf(ctx, errors.New("name required"), "checking template")
+	}
+	if strings.ContainsAny(t.Subject, "\r\n") {
+		xcheckuserf(ctx, errors.New("subject cannot contain newlines"), "checking template")
+	}
+	var size int
+	for _, a := range t.Attachments {
+		_, _, err := parseDataURI(a.DataURI)
+		xcheckuserf(ctx, err, "parsing template attachment")
+		size += len(a.DataURI)
+	}
+	if size > templateAttachmentsMax {
+		xcheckuserf(ctx, fmt.Errorf("attachments too large, max %d bytes", templateAttachmentsMax), "checking template")
+	}
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		var err error
+		if t.ID == 0 {
+			err = tx.Insert(&t)
+		} else {
+			if err := tx.Get(&store.Template{ID: t.ID}); err == bstore.ErrAbsent {
+				xcheckuserf(ctx, err, "get template")
+			}
+			err = tx.Update(&t)
+		}
+		if errors.Is(err, bstore.ErrUnique) {
+			xcheckuserf(ctx, errors.New("template with that name already exists"), "saving template")
+		}
+		xcheckf(ctx, err, "saving template")
+	})
+	return t
+}
+
+// TemplateRemove removes a template from the account.
+func (Webmail) TemplateRemove(ctx context.Context, templateID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	err := acc.DB.Delete(ctx, &store.Template{ID: templateID})
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, err, "removing template")
+	}
+	xcheckf(ctx, err, "removing template")
+}
+
+// TemplateApply returns the subject, text and attachments of a template for
+// composing a message from fromAddress to recipients "to", with placeholders
+// replaced. Placeholders for the recipient are replaced with the name and address
+// of the first recipient.
+func (Webmail) TemplateApply(ctx context.Context, ref TemplateRef, fromAddress string, to []string) ComposeTemplate {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	var ct ComposeTemplate
+	accConf, _ := acc.Conf()
+	if ref.ID != 0 {
+		t := store.Template{ID: ref.ID}
+		err := acc.DB.Get(ctx, &t)
+		if err == bstore.ErrAbsent {
+			xcheckuserf(ctx, err, "get template")
+		}
+		xcheckf(ctx, err, "get template")
+		ct = ComposeTemplate{t.Subject, t.Text, []File{}}
+		for _, a := range t.Attachments {
+			ct.Attachments = append(ct.Attachments, File(a))
+		}
+	} else {
+		shared := xsharedTemplates(ctx, accConf)
+		i := slices.IndexFunc(shared, func(t SharedTemplate) bool {
+			return t.Domain == ref.Domain && t.Name == ref.Name
+		})
+		if i < 0 {
+			xcheckuserf(ctx, errors.New("no such template"), "get template")
+		}
+		ct = ComposeTemplate{shared[i].Subject, shared[i].Text, []File{}}
+	}
+
+	var senderName, senderEmail, rcptName, rcptEmail string
+	if fromAddress != "" {
+		addr, err := parseAddress(fromAddress)
+		xcheckuserf(ctx, err, "parsing from address")
+		senderName, senderEmail = cmp.Or(addr.DisplayName, accConf.FullName), addr.Address.String()
+	}
+	if len(to) > 0 && to[0] != "" {
+		addr, err := parseAddress(to[0])
+		xcheckuserf(ctx, err, "parsing recipient address")
+		rcptName, rcptEmail = addr.DisplayName, addr.Address.String()
+	}
+	rcptFirstName, _, _ := strings.Cut(rcptName, " ")
+	r := strings.NewReplacer(
+		"{{recipient.name}}", rcptName,
+		"{{recipient.firstname}}", rcptFirstName,
+		"{{recipient.email}}", rcptEmail,
+		"{{sender.name}}", senderName,
+		"{{sender.email}}", senderEmail,
+		"{{date}}", time.Now().Format("2 January 2006"),
+	)
+	ct.Subject = r.Replace(ct.Subject)
+	ct.Text = r.Replace(ct.Text)
+	return ct
+}
+
+func (Webmail) RulesetSuggestMove(ctx context.Context, msgID, mbSrcID, mbDstID int64) (listID string, msgFrom string, isRemove bool, rcptTo string, ruleset *config.Ruleset) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		m := xmessageID(ctx, tx, msgID)
+		mbSrc := xmailboxID(ctx, tx, mbSrcID)
+		mbDst := xmailboxID(ctx, tx, mbDstID)
+
+		if m.RcptToLocalpart == "" && m.RcptToDomain == "" {
+			return
+		}
+		rcptTo = m.RcptToLocalpart.String() + "@" + m.RcptToDomain
+
+		conf, _ := acc.Conf()
 		dest := conf.Destinations[rcptTo] // May not be present.
 		defaultMailbox := "Inbox"
 		if dest.Mailbox != "" {
@@ -2066,6 +3482,812 @@ func (Webmail) RulesetMailboxNever(ctx context.Context, mailboxID int64, toMailb
 	xcheckf(ctx, err, "storing user response")
 }
 
+// Rules returns the message rules of the account, in order of evaluation.
+func (Webmail) Rules(ctx context.Context) []store.Rule {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	l, err := bstore.QueryDB[store.Rule](ctx, acc.DB).SortAsc("Position", "ID").List()
+	xcheckf(ctx, err, "listing rules")
+	return l
+}
+
+// RuleSave adds a rule to the account if its ID is zero, and otherwise updates
+// the existing rule. The saved rule is returned.
+func (Webmail) RuleSave(ctx context.Context, r store.Rule) store.Rule {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		xcheckuserf(ctx, errors.New("name required"), "checking rule")
+	}
+	if r.Mailbox == "" && !r.MarkRead && len(r.ForwardTo) == 0 && !r.Delete {
+		xcheckuserf(ctx, errors.New("at least one action required"), "checking rule")
+	}
+	if r.Mailbox != "" && r.Delete {
+		xcheckuserf(ctx, errors.New("cannot both move and delete"), "checking rule")
+	}
+	for i, s := range r.ForwardTo {
+		addr, err := smtp.ParseAddress(strings.TrimSpace(s))
+		xcheckuserf(ctx, err, "parsing forward address")
+		r.ForwardTo[i] = addr.String()
+	}
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		if r.Mailbox != "" {
+			mb, err := acc.MailboxFind(tx, r.Mailbox)
+			xcheckf(ctx, err, "looking up mailbox")
+			if mb == nil {
+				xcheckuserf(ctx, errors.New("mailbox not found"), "checking rule")
+			}
+		}
+
+		var err error
+		if r.ID == 0 {
+			err = tx.Insert(&r)
+		} else {
+			if err := tx.Get(&store.Rule{ID: r.ID}); err == bstore.ErrAbsent {
+				xcheckuserf(ctx, err, "get rule")
+			}
+			err = tx.Update(&r)
+		}
+		xcheckf(ctx, err, "saving rule")
+	})
+	return r
+}
+
+// RuleRemove removes a rule from the account.
+func (Webmail) RuleRemove(ctx context.Context, ruleID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	err := acc.DB.Delete(ctx, &store.Rule{ID: ruleID})
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, err, "removing rule")
+	}
+	xcheckf(ctx, err, "removing rule")
+}
+
+// RuleRun applies the enabled rules to the existing messages in a mailbox, moving
+// messages and marking them read. Messages are not forwarded. The number of
+// messages that matched a rule is returned.
+func (Webmail) RuleRun(ctx context.Context, mailboxID int64) int {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	var ids []int64
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		mb := xmailboxID(ctx, tx, mailboxID)
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterNonzero(store.Message{MailboxID: mb.ID})
+		q.FilterEqual("Expunged", false)
+		err := q.IDs(&ids)
+		xcheckf(ctx, err, "listing messages")
+	})
+
+	matched, _, err := webops.RulesApply(ctx, log, acc, ids, false)
+	xcheckf(ctx, err, "applying rules")
+	return matched
+}
+
+// SavedSearches returns the saved searches of the account, with message counts.
+func (Webmail) SavedSearches(ctx context.Context) []SavedSearch {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	accConf, _ := acc.Conf()
+	var l []SavedSearch
+	xdbread(ctx, acc, func(tx *bstore.Tx) {
+		var err error
+		l, err = savedSearchesList(ctx, log, acc, tx, accConf.RejectsMailbox)
+		xcheckf(ctx, err, "listing saved searches")
+	})
+	return l
+}
+
+// SavedSearchSave adds a saved search to the account if its ID is zero, and
+// otherwise updates the existing saved search. The saved search is returned with
+// message counts.
+func (Webmail) SavedSearchSave(ctx context.Context, ss SavedSearch) SavedSearch {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	ss.Name = strings.TrimSpace(ss.Name)
+	if ss.Name == "" {
+		xcheckuserf(ctx, errors.New("name required"), "checking saved search")
+	}
+
+	accConf, _ := acc.Conf()
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		// The webmail client sets a mailbox name if it doesn't know the mailbox.
+		if ss.Filter.MailboxName != "" {
+			mb, err := acc.MailboxFind(tx, ss.Filter.MailboxName)
+			xcheckf(ctx, err, "looking up mailbox")
+			if mb == nil {
+				xcheckuserf(ctx, errors.New("mailbox not found"), "checking saved search")
+			}
+			ss.Filter.MailboxID = mb.ID
+			ss.Filter.MailboxName = ""
+		}
+
+		exists, err := bstore.QueryTx[store.SavedSearch](tx).FilterNonzero(store.SavedSearch{Name: ss.Name}).FilterNotEqual("ID", ss.ID).Exists()
+		xcheckf(ctx, err, "checking for duplicate name")
+		if exists {
+			xcheckuserf(ctx, errors.New("saved search with name already exists"), "checking saved search")
+		}
+
+		buf, err := json.Marshal(savedSearchFilters{ss.Filter, ss.NotFilter})
+		xcheckf(ctx, err, "marshal filters")
+		xss := store.SavedSearch{ID: ss.ID, Name: ss.Name, Search: ss.Search, Filters: string(buf)}
+		if xss.ID == 0 {
+			err = tx.Insert(&xss)
+		} else {
+			if err := tx.Get(&store.SavedSearch{ID: xss.ID}); err == bstore.ErrAbsent {
+				xcheckuserf(ctx, err, "get saved search")
+			}
+			err = tx.Update(&xss)
+		}
+		xcheckf(ctx, err, "saving saved search")
+		ss.ID = xss.ID
+
+		err = savedSearchCount(ctx, log, acc, tx, accConf.RejectsMailbox, &ss)
+		xcheckf(ctx, err, "counting messages for saved search")
+	})
+	return ss
+}
+
+// SavedSearchRemove removes a saved search from the account.
+func (Webmail) SavedSearchRemove(ctx context.Context, savedSearchID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	err := acc.DB.Delete(ctx, &store.SavedSearch{ID: savedSearchID})
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, err, "removing saved search")
+	}
+	xcheckf(ctx, err, "removing saved search")
+}
+
+// Drafts returns the autosaved drafts of messages being composed, most recently
+// saved first, e.g. for continuing after the browser was closed. Drafts that
+// haven't been saved for 30 days are removed.
+func (Webmail) Drafts(ctx context.Context) []Draft {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	l := []Draft{}
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		xdraftsCleanup(ctx, tx)
+
+		drafts, err := bstore.QueryTx[store.Draft](tx).SortDesc("Updated").List()
+		xcheckf(ctx, err, "listing drafts")
+		for _, d := range drafts {
+			l = append(l, xdraft(ctx, tx, d))
+		}
+	})
+	return l
+}
+
+// DraftSave autosaves the compose state of a draft. A new draft is created if ID
+// is 0. For existing drafts, Version must be the version of the last save, which
+// is incremented, otherwise the draft was changed from another device or browser
+// window and error code "user:draftConflict" is returned.
+func (Webmail) DraftSave(ctx context.Context, d Draft) Draft {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	d.Message.DraftID = 0
+	buf, err := json.Marshal(d.Message)
+	xcheckf(ctx, err, "marshal compose state")
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		sd := store.Draft{Updated: time.Now(), Compose: string(buf)}
+		if d.ID == 0 {
+			xdraftsCleanup(ctx, tx)
+			sd.Version = 1
+			err := tx.Insert(&sd)
+			xcheckf(ctx, err, "adding draft")
+		} else {
+			cur := xdraftGet(ctx, tx, d.ID)
+			if cur.Version != d.Version {
+				xdraftConflict(cur)
+			}
+			sd.ID = cur.ID
+			sd.Created = cur.Created
+			sd.Version = cur.Version + 1
+			err := tx.Update(&sd)
+			xcheckf(ctx, err, "saving draft")
+		}
+		d = xdraft(ctx, tx, sd)
+	})
+	return d
+}
+
+// DraftRemove removes a draft and its attachments.
+func (Webmail) DraftRemove(ctx context.Context, draftID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		xdraftGet(ctx, tx, draftID)
+		xdraftRemove(ctx, tx, draftID)
+	})
+}
+
+// DraftAttachmentAdd uploads an attachment for a draft, so it doesn't have to be
+// uploaded again with each save or when sending.
+func (w Webmail) DraftAttachmentAdd(ctx context.Context, draftID int64, f File) DraftAttachment {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	ct, data, err := parseDataURI(f.DataURI)
+	xcheckuserf(ctx, err, "parsing attachment")
+	buf, err := base64.StdEncoding.DecodeString(data)
+	xcheckuserf(ctx, err, "decoding attachment")
+	filename := f.Filename
+	if filename == "" {
+		filename = "unnamed.bin"
+	}
+	// Parameters from the data URI are kept, e.g. "method" for text/calendar.
+	mt, params, err := mime.ParseMediaType(ct)
+	xcheckuserf(ctx, err, "parsing attachment content-type")
+	params["name"] = filename
+	ct = mime.FormatMediaType(mt, params)
+
+	a := store.DraftAttachment{DraftID: draftID, Filename: filename, ContentType: ct, Data: buf}
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		d := xdraftGet(ctx, tx, draftID)
+
+		size := int64(len(buf))
+		for _, xa := range xdraftAttachments(ctx, tx, draftID) {
+			size += int64(len(xa.Data))
+		}
+		if size > w.maxMessageSize {
+			xcheckuserf(ctx, message.ErrMessageSize, "checking size of attachments")
+		}
+
+		err := tx.Insert(&a)
+		xcheckf(ctx, err, "adding draft attachment")
+
+		d.Updated = time.Now()
+		err = tx.Update(&d)
+		xcheckf(ctx, err, "updating draft")
+	})
+	return DraftAttachment{a.ID, a.Filename, a.ContentType, int64(len(a.Data))}
+}
+
+// DraftAttachmentRemove removes an uploaded attachment from a draft.
+func (Webmail) DraftAttachmentRemove(ctx context.Context, attachmentID int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		a := store.DraftAttachment{ID: attachmentID}
+		err := tx.Get(&a)
+		if err == bstore.ErrAbsent {
+			xcheckuserf(ctx, err, "get draft attachment")
+		}
+		xcheckf(ctx, err, "get draft attachment")
+		err = tx.Delete(&a)
+		xcheckf(ctx, err, "removing draft attachment")
+	})
+}
+
+var labelColorRegexp = regexp.MustCompile(`^#[0-9a-f]{6}$`)
+
+// xcheckLabelName checks name is a valid keyword for use as label, and returns it
+// in lower case.
+func xcheckLabelName(ctx context.Context, name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	err := store.CheckKeyword(name)
+	xcheckuserf(ctx, err, "checking label name")
+	if strings.HasPrefix(name, "$") {
+		xcheckuserf(ctx, errors.New("keywords starting with $ are reserved"), "checking label name")
+	} else if strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/") || strings.Contains(name, "//") {
+		xcheckuserf(ctx, errors.New("empty label name in hierarchy"), "checking label name")
+	}
+	return name
+}
+
+// xlabelsUpdate calls fn with the labels of the account, and stores the returned
+// labels in the settings.
+func xlabelsUpdate(ctx context.Context, tx *bstore.Tx, fn func(l []store.Label) []store.Label) {
+	settings := store.Settings{ID: 1}
+	err := tx.Get(&settings)
+	xcheckf(ctx, err, "get settings")
+	settings.Labels = fn(settings.Labels)
+	err = tx.Update(&settings)
+	xcheckf(ctx, err, "save settings")
+}
+
+// labelBelow returns whether name is label or below it in the hierarchy.
+func labelBelow(name, label string) bool {
+	return name == label || strings.HasPrefix(name, label+"/")
+}
+
+// LabelSave adds a label to the end of the list of labels, or updates the color
+// of an existing label. Messages are not changed.
+func (Webmail) LabelSave(ctx context.Context, label store.Label) store.Label {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	label.Name = xcheckLabelName(ctx, label.Name)
+	label.Color = strings.ToLower(label.Color)
+	if label.Color != "" && !labelColorRegexp.MatchString(label.Color) {
+		xcheckuserf(ctx, errors.New(`color must be of the form "#rrggbb"`), "checking label color")
+	}
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		xlabelsUpdate(ctx, tx, func(l []store.Label) []store.Label {
+			i := slices.IndexFunc(l, func(xl store.Label) bool { return xl.Name == label.Name })
+			if i < 0 {
+				return append(l, label)
+			}
+			l[i] = label
+			return l
+		})
+	})
+	return label
+}
+
+// LabelRename renames a label, and the labels below it in the hierarchy. The
+// keywords of affected messages are changed in the same transaction. If a label
+// with the new name already exists, messages with the old label get the existing
+// label. The number of changed messages is returned.
+func (Webmail) LabelRename(ctx context.Context, oldName, newName string) int {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	oldName = xcheckLabelName(ctx, oldName)
+	newName = xcheckLabelName(ctx, newName)
+	if oldName == newName {
+		return 0
+	} else if labelBelow(newName, oldName) {
+		xcheckuserf(ctx, errors.New("cannot rename label to below itself"), "checking label name")
+	}
+
+	var n int
+	acc.WithWLock(func() {
+		var changes []store.Change
+
+		xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+			xlabelsUpdate(ctx, tx, func(l []store.Label) []store.Label {
+				var nl []store.Label
+				for _, xl := range l {
+					if labelBelow(xl.Name, oldName) {
+						xl.Name = newName + xl.Name[len(oldName):]
+					}
+					if !slices.ContainsFunc(nl, func(ol store.Label) bool { return ol.Name == xl.Name }) {
+						nl = append(nl, xl)
+					}
+				}
+				return nl
+			})
+
+			var modseq store.ModSeq
+			n, changes = xops.KeywordRenameTx(ctx, log, acc, tx, oldName, newName, &modseq)
+		})
+
+		store.BroadcastChanges(acc, changes)
+	})
+	return n
+}
+
+// LabelRemove removes a label, and the labels below it in the hierarchy. The
+// keywords are removed from messages in the same transaction. The number of
+// changed messages is returned.
+func (Webmail) LabelRemove(ctx context.Context, name string) int {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+	log := reqInfo.Log
+
+	name = xcheckLabelName(ctx, name)
+
+	var n int
+	acc.WithWLock(func() {
+		var changes []store.Change
+
+		xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+			xlabelsUpdate(ctx, tx, func(l []store.Label) []store.Label {
+				return slices.DeleteFunc(l, func(xl store.Label) bool { return labelBelow(xl.Name, name) })
+			})
+
+			var modseq store.ModSeq
+			n, changes = xops.KeywordRenameTx(ctx, log, acc, tx, name, "", &modseq)
+		})
+
+		store.BroadcastChanges(acc, changes)
+	})
+	return n
+}
+
+// LabelsOrder sets the display order of labels. Names must be those of all
+// existing labels.
+func (Webmail) LabelsOrder(ctx context.Context, names []string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		xlabelsUpdate(ctx, tx, func(l []store.Label) []store.Label {
+			if len(names) != len(l) {
+				xcheckuserf(ctx, errors.New("names must be of all labels"), "checking label order")
+			}
+			var nl []store.Label
+			for _, name := range names {
+				i := slices.IndexFunc(l, func(xl store.Label) bool { return xl.Name == name })
+				if i < 0 || slices.ContainsFunc(nl, func(xl store.Label) bool { return xl.Name == name }) {
+					xcheckuserf(ctx, fmt.Errorf("unknown or duplicate label %q", name), "checking label order")
+				}
+				nl = append(nl, l[i])
+			}
+			return nl
+		})
+	})
+}
+
+// WebPushKey returns the public key of the account for Web Push, base64url
+// encoded, for use as "applicationServerKey" when subscribing in the browser.
+func (Webmail) WebPushKey(ctx context.Context) string {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	var pub []byte
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		key, err := webpush.KeyTx(tx)
+		xcheckf(ctx, err, "get web push key")
+		pub, err = webpush.PublicKey(key)
+		xcheckf(ctx, err, "get public key")
+	})
+	return base64.RawURLEncoding.EncodeToString(pub)
+}
+
+// WebPushSubscribe adds a Web Push subscription of the browser, or updates the
+// keys of an existing subscription with the same endpoint. Notifications about
+// new messages matching the settings are sent to the subscription. The keys are
+// base64url encoded, as returned by the browser.
+func (Webmail) WebPushSubscribe(ctx context.Context, endpoint, p256dh, auth string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	sub, err := webpush.NewSubscription(endpoint, p256dh, auth, reqInfo.Request.UserAgent())
+	xcheckuserf(ctx, err, "checking subscription")
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		_, err := bstore.QueryTx[store.WebPushSubscription](tx).FilterNonzero(store.WebPushSubscription{Endpoint: endpoint}).Delete()
+		xcheckf(ctx, err, "removing existing subscription")
+
+		// Remove the oldest subscriptions if we have too many.
+		subs, err := bstore.QueryTx[store.WebPushSubscription](tx).SortDesc("Created", "ID").List()
+		xcheckf(ctx, err, "listing subscriptions")
+		for _, s := range subs[min(len(subs), webpush.MaxSubscriptions-1):] {
+			err := tx.Delete(&s)
+			xcheckf(ctx, err, "removing old subscription")
+		}
+
+		err = tx.Insert(&sub)
+		xcheckf(ctx, err, "adding subscription")
+	})
+}
+
+// WebPushUnsubscribe removes the Web Push subscription with the endpoint.
+func (Webmail) WebPushUnsubscribe(ctx context.Context, endpoint string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+		_, err := bstore.QueryTx[store.WebPushSubscription](tx).FilterNonzero(store.WebPushSubscription{Endpoint: endpoint}).Delete()
+		xcheckf(ctx, err, "removing subscription")
+	})
+}
+
+// DelegatedAccess is access to mailboxes of another account, given to the
+// account of the session.
+type DelegatedAccess struct {
+	Account   string // Account that gave access.
+	Mailboxes []string
+	Write     bool
+	Send      string   // Empty, "as" or "onbehalf".
+	Addresses []string // Addresses of the account, for sending messages, only if Send is set.
+}
+
+// delegatedAccess returns the access given to account accName by other accounts.
+func delegatedAccess(accName string) []DelegatedAccess {
+	var l []DelegatedAccess
+	for _, owner := range mox.Conf.Accounts() {
+		d, ok := mox.AccountDelegation(owner, accName)
+		if !ok {
+			continue
+		}
+		da := DelegatedAccess{owner, d.Mailboxes, d.Write, d.Send, nil}
+		if d.Send != "" {
+			accConf, _ := mox.Conf.Account(owner)
+			for addr := range accConf.Destinations {
+				// Catchall addresses can't be sent from.
+				if strings.Contains(addr, "@") && !strings.HasPrefix(addr, "@") {
+					da.Addresses = append(da.Addresses, addr)
+				}
+			}
+			slices.Sort(da.Addresses)
+		}
+		l = append(l, da)
+	}
+	slices.SortFunc(l, func(a, b DelegatedAccess) int {
+		return cmp.Compare(a.Account, b.Account)
+	})
+	return l
+}
+
+// Delegations returns the access to mailboxes the account has given to other
+// accounts, and the access other accounts have given to the account.
+func (Webmail) Delegations(ctx context.Context) (given []config.Delegation, received []DelegatedAccess) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	accConf, _ := acc.Conf()
+	return accConf.Delegations, delegatedAccess(acc.Name)
+}
+
+// DelegationSave gives another account access to mailboxes, replacing access
+// given earlier to that account.
+func (Webmail) DelegationSave(ctx context.Context, d config.Delegation) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	if _, ok := mox.Conf.Account(d.Account); !ok {
+		xcheckuserf(ctx, errors.New("account not found"), "checking delegation")
+	} else if d.Account == acc.Name {
+		xcheckuserf(ctx, errors.New("cannot delegate to own account"), "checking delegation")
+	}
+	var mailboxes []string
+	for _, name := range d.Mailboxes {
+		name = strings.TrimSpace(name)
+		if name != "" && !slices.Contains(mailboxes, name) {
+			mailboxes = append(mailboxes, name)
+		}
+	}
+	if len(mailboxes) == 0 {
+		xcheckuserf(ctx, errors.New("at least one mailbox required"), "checking delegation")
+	}
+	d.Mailboxes = mailboxes
+	switch d.Send {
+	case "", "as", "onbehalf":
+	default:
+		xcheckuserf(ctx, fmt.Errorf("unknown send %q", d.Send), "checking delegation")
+	}
+
+	err := admin.AccountSave(ctx, acc.Name, func(accConf *config.Account) {
+		l := slices.DeleteFunc(slices.Clone(accConf.Delegations), func(od config.Delegation) bool {
+			return od.Account == d.Account
+		})
+		accConf.Delegations = append(l, d)
+	})
+	xcheckf(ctx, err, "saving account with delegation")
+}
+
+// DelegationRemove removes access given to another account.
+func (Webmail) DelegationRemove(ctx context.Context, account string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	acc := reqInfo.Account
+
+	err := admin.AccountSave(ctx, acc.Name, func(accConf *config.Account) {
+		l := slices.DeleteFunc(slices.Clone(accConf.Delegations), func(d config.Delegation) bool {
+			return d.Account == account
+		})
+		if len(l) == len(accConf.Delegations) {
+			xcheckuserf(ctx, errors.New("no delegation to account"), "removing delegation")
+		}
+		accConf.Delegations = l
+	})
+	xcheckf(ctx, err, "saving account without delegation")
+}
+
+// xdelegatedAccount opens the account owner, which must have given access to the
+// account of the session. The caller must close the account.
+func xdelegatedAccount(ctx context.Context, owner string) (*store.Account, config.Delegation) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	d, ok := mox.AccountDelegation(owner, reqInfo.Account.Name)
+	if !ok {
+		xcheckuserf(ctx, errors.New("no access to account"), "checking delegation")
+	}
+	oacc, err := store.OpenAccount(reqInfo.Log, owner, false)
+	xcheckf(ctx, err, "open account")
+	return oacc, d
+}
+
+// xdelegatedMailbox returns a mailbox of a delegated account by name. The mailbox
+// must be part of the delegation.
+func xdelegatedMailbox(ctx context.Context, tx *bstore.Tx, oacc *store.Account, d config.Delegation, name string) store.Mailbox {
+	if !slices.Contains(d.Mailboxes, name) {
+		xcheckuserf(ctx, errors.New("no access to mailbox"), "checking delegation")
+	}
+	mb, err := oacc.MailboxFind(tx, name)
+	xcheckf(ctx, err, "looking up mailbox")
+	if mb == nil {
+		xcheckuserf(ctx, errors.New("mailbox not found"), "looking up mailbox")
+	}
+	return *mb
+}
+
+// xdelegatedMessages checks that messages are in mailboxes of the delegation.
+func xdelegatedMessages(ctx context.Context, tx *bstore.Tx, oacc *store.Account, d config.Delegation, messageIDs []int64) {
+	mailboxIDs := map[int64]bool{}
+	for _, name := range d.Mailboxes {
+		mb, err := oacc.MailboxFind(tx, name)
+		xcheckf(ctx, err, "looking up mailbox")
+		if mb != nil {
+			mailboxIDs[mb.ID] = true
+		}
+	}
+	for _, id := range messageIDs {
+		m := xmessageID(ctx, tx, id)
+		if !mailboxIDs[m.MailboxID] {
+			xcheckuserf(ctx, errors.New("no access to message"), "checking delegation")
+		}
+	}
+}
+
+// DelegatedMessages returns messages from a mailbox of another account that gave
+// access, most recently received first.
+func (Webmail) DelegatedMessages(ctx context.Context, owner, mailbox string, offset, limit int) []MessageItem {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	if offset < 0 || limit <= 0 || limit > 1000 {
+		xcheckuserf(ctx, errors.New("offset must be >= 0, limit between 1 and 1000"), "checking parameters")
+	}
+
+	oacc, d := xdelegatedAccount(ctx, owner)
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	var l []MessageItem
+	xdbread(ctx, oacc, func(tx *bstore.Tx) {
+		mb := xdelegatedMailbox(ctx, tx, oacc, d, mailbox)
+
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterNonzero(store.Message{MailboxID: mb.ID})
+		q.FilterEqual("Expunged", false)
+		q.SortDesc("Received")
+		q.Limit(offset + limit)
+		msgs, err := q.List()
+		xcheckf(ctx, err, "listing messages")
+
+		state := msgState{acc: oacc}
+		defer state.clear()
+		for _, m := range msgs[min(offset, len(msgs)):] {
+			mi, err := messageItem(log, m, &state, nil)
+			xcheckf(ctx, err, "making message item")
+			l = append(l, mi)
+		}
+	})
+	return l
+}
+
+// DelegatedParsedMessage returns a message from a mailbox of another account that
+// gave access.
+func (Webmail) DelegatedParsedMessage(ctx context.Context, owner string, msgID int64) (pm ParsedMessage) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	oacc, d := xdelegatedAccount(ctx, owner)
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	xdbread(ctx, oacc, func(tx *bstore.Tx) {
+		xdelegatedMessages(ctx, tx, oacc, d, []int64{msgID})
+		m := xmessageID(ctx, tx, msgID)
+
+		state := msgState{acc: oacc}
+		defer state.clear()
+		var err error
+		pm, err = parsedMessage(log, &m, &state, true, false, false)
+		xcheckf(ctx, err, "parsing message")
+	})
+	return
+}
+
+// xdelegatedWrite opens the account owner for changing messages, which must have
+// given write access to the account of the session. The returned XOps check that
+// the messages are in mailboxes of the delegation in the same transaction that
+// changes them, so messages moved out of the delegated mailboxes in the mean time
+// cannot be changed. The caller must close the account.
+func xdelegatedWrite(ctx context.Context, owner string, messageIDs []int64) (*store.Account, config.Delegation, webops.XOps) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+
+	oacc, d := xdelegatedAccount(ctx, owner)
+	if !d.Write {
+		err := oacc.Close()
+		reqInfo.Log.Check(err, "closing account")
+		xcheckuserf(ctx, errors.New("no write access to account"), "checking delegation")
+	}
+	x := xops
+	x.DBWrite = func(ctx context.Context, acc *store.Account, fn func(tx *bstore.Tx)) {
+		xdbwrite(ctx, acc, func(tx *bstore.Tx) {
+			xdelegatedMessages(ctx, tx, acc, d, messageIDs)
+			fn(tx)
+		})
+	}
+	return oacc, d, x
+}
+
+// DelegatedFlagsAdd adds flags to messages in mailboxes of another account that
+// gave write access.
+func (Webmail) DelegatedFlagsAdd(ctx context.Context, owner string, messageIDs []int64, flaglist []string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	oacc, _, x := xdelegatedWrite(ctx, owner, messageIDs)
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	x.MessageFlagsAdd(ctx, log, oacc, messageIDs, flaglist)
+}
+
+// DelegatedFlagsClear clears flags of messages in mailboxes of another account
+// that gave write access.
+func (Webmail) DelegatedFlagsClear(ctx context.Context, owner string, messageIDs []int64, flaglist []string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	oacc, _, x := xdelegatedWrite(ctx, owner, messageIDs)
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	x.MessageFlagsClear(ctx, log, oacc, messageIDs, flaglist)
+}
+
+// DelegatedMessageMove moves messages to another mailbox of the delegation by
+// another account that gave write access.
+func (Webmail) DelegatedMessageMove(ctx context.Context, owner string, messageIDs []int64, mailbox string) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	oacc, d, x := xdelegatedWrite(ctx, owner, messageIDs)
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	// The mailbox is looked up by name in the transaction that moves the messages.
+	if !slices.Contains(d.Mailboxes, mailbox) {
+		xcheckuserf(ctx, errors.New("no access to mailbox"), "checking delegation")
+	}
+	x.MessageMove(ctx, log, oacc, messageIDs, mailbox, 0)
+}
+
+// DelegatedMessageDelete permanently deletes messages in mailboxes of another
+// account that gave write access.
+func (Webmail) DelegatedMessageDelete(ctx context.Context, owner string, messageIDs []int64) {
+	reqInfo := ctx.Value(requestInfoCtxKey).(requestInfo)
+	log := reqInfo.Log
+
+	if len(messageIDs) == 0 {
+		return
+	}
+
+	oacc, _, x := xdelegatedWrite(ctx, owner, messageIDs)
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	x.MessageDelete(ctx, log, oacc, messageIDs)
+}
+
 func slicesAny[T any](l []T) []any {
 	r := make([]any, len(l))
 	for i, v := range l {
@@ -2075,6 +4297,6 @@ func slicesAny[T any](l []T) []any {
 }
 
 // SSETypes exists to ensure the generated API contains the types, for use in SSE events.
-func (Webmail) SSETypes() (start EventStart, viewErr EventViewErr, viewReset EventViewReset, viewMsgs EventViewMsgs, viewChanges EventViewChanges, msgAdd ChangeMsgAdd, msgRemove ChangeMsgRemove, msgFlags ChangeMsgFlags, msgThread ChangeMsgThread, mailboxRemove ChangeMailboxRemove, mailboxAdd ChangeMailboxAdd, mailboxRename ChangeMailboxRename, mailboxCounts ChangeMailboxCounts, mailboxSpecialUse ChangeMailboxSpecialUse, mailboxKeywords ChangeMailboxKeywords, flags store.Flags) {
+func (Webmail) SSETypes() (start EventStart, viewErr EventViewErr, viewReset EventViewReset, viewMsgs EventViewMsgs, viewChanges EventViewChanges, msgAdd ChangeMsgAdd, msgRemove ChangeMsgRemove, msgFlags ChangeMsgFlags, msgThread ChangeMsgThread, mailboxRemove ChangeMailboxRemove, mailboxAdd ChangeMailboxAdd, mailboxRename ChangeMailboxRename, mailboxCounts ChangeMailboxCounts, mailboxSpecialUse ChangeMailboxSpecialUse, mailboxKeywords ChangeMailboxKeywords, savedSearches EventSavedSearches, bulkProgress EventBulkProgress, resume ViewResume, flags store.Flags) {
 	return
 }
@@ -2,6 +2,23 @@
 
 namespace api {
 
+// PasskeyGetOptions are the parameters for navigator.credentials.get in the
+// browser for a passkey login. No credentials are specified: the browser lets
+// the user choose from the passkeys registered for the site.
+export interface PasskeyGetOptions {
+	Challenge?: string | null
+	RPID: string
+	Timeout: number  // In milliseconds.
+}
+
+// PasskeyAssertion is the response from navigator.credentials.get.
+export interface PasskeyAssertion {
+	CredentialID?: string | null
+	ClientDataJSON?: string | null
+	AuthenticatorData?: string | null
+	Signature?: string | null
+}
+
 // Request is a request to an SSE connection to send messages, either for a new
 // view, to continue with an existing view, or to a cancel an ongoing request.
 export interface Request {
@@ -9,6 +26,7 @@ export interface Request {
 	SSEID: number  // SSE connection.
 	ViewID: number  // To indicate a request is a continuation (more results) of the previous view. Echoed in events, client checks if it is getting results for the latest request.
 	Cancel: boolean  // If set, this request and its view are canceled. A new view must be started.
+	KeepViews: boolean  // If set, other views on the connection are kept, with changes sent for each of them, e.g. for showing multiple mailboxes at the same time. Otherwise, a request for a new view closes the other views.
 	Query: Query
 	Page: Page
 }
@@ -28,6 +46,7 @@ export interface Filter {
 	MailboxChildrenIncluded: boolean  // If true, also submailboxes are included in the search.
 	MailboxName: string  // In case client doesn't know mailboxes and their IDs yet. Only used during sse connection setup, where it is turned into a MailboxID. Filtering only looks at MailboxID.
 	Words?: string[] | null  // Case insensitive substring match for each string.
+	WordHashes?: string[] | null  // For accounts with a hashed search index: Hashes of whole words, see EventStart.SearchIndexSalt.
 	From?: string[] | null
 	To?: string[] | null  // Including Cc and Bcc.
 	Oldest?: Date | null
@@ -43,6 +62,7 @@ export interface Filter {
 // NotFilter matches messages that don't match these fields.
 export interface NotFilter {
 	Words?: string[] | null
+	WordHashes?: string[] | null
 	From?: string[] | null
 	To?: string[] | null
 	Subject?: string[] | null
@@ -70,6 +90,8 @@ export interface ParsedMessage {
 	ListReplyAddress?: MessageAddress | null  // From List-Post.
 	TextPaths?: (number[] | null)[] | null  // Paths to text parts.
 	HTMLPath?: number[] | null  // Path to HTML part.
+	SMIMECertificate?: SMIMECertificate | null  // Of the signer, for messages with an S/MIME signature.
+	SMIMEPinned: string  // Fingerprint of the S/MIME key pinned for the From address. If it differs from the key that signed this message, the sender switched keys.
 }
 
 // Part represents a whole mail message, or a part of a multipart message. It
@@ -134,12 +156,45 @@ export interface Domain {
 	Unicode: string  // Name as U-labels, in Unicode NFC. Empty if this is an ASCII-only domain. No trailing dot.
 }
 
+// SMIMECertificate has details of the certificate that signed a message.
+export interface SMIMECertificate {
+	Subject: string
+	Issuer: string
+	SerialNumber: string  // Hex.
+	NotBefore: Date
+	NotAfter: Date
+	Chain?: string[] | null  // Subjects of verified certificate chain, from signer to root.
+}
+
 // FromAddressSettings are webmail client settings per "From" address.
 export interface FromAddressSettings {
 	FromAddress: string  // Unicode.
 	ViewMode: ViewMode
 }
 
+// MessageJunkVerdict is the outcome of the junk analysis during delivery over
+// SMTP, with the signals that contributed to it. Stored with the message to
+// explain why it was delivered to the Junk or Rejects mailbox, or accepted.
+export interface MessageJunkVerdict {
+	Accept: boolean  // Whether the analysis accepted the message. Rejected messages are stored in the Rejects mailbox.
+	Reason: string  // Reason for the decision, as in the X-Mox-Reason header, e.g. "junk-content", "dmarc-policy", "no-bad-signals" or a reputation method like "msgfromfull".
+	Details?: string[] | null  // Human-readable descriptions of the evaluated signals.
+	Mailbox: string  // Mailbox selected by the analysis, e.g. Junk for quarantined messages. Empty for the mailbox of the destination.
+	Classified: boolean  // Whether the junk filter classified the message, not done for senders with a conclusive reputation. The fields below are only set if so.
+	Score: number  // Spam probability, between 0 (ham) and 1 (spam). Combined with the shared junk filter of the domain, if configured.
+	Threshold: number  // Messages with a higher score are junk. Can be stricter than configured, e.g. for connections without TLS.
+	Significant: boolean  // Whether enough known words were found for the score to be used.
+	HamWords?: MessageJunkWord[] | null
+	SpamWords?: MessageJunkWord[] | null
+}
+
+// MessageJunkWord is a word (or word combination) that contributed to the score
+// of the junk filter.
+export interface MessageJunkWord {
+	Word: string
+	Score: number  // Spaminess, 0 is ham, 1 is spam.
+}
+
 // ComposeMessage is a message to be composed, for saving draft messages.
 export interface ComposeMessage {
 	From: string
@@ -151,6 +206,30 @@ export interface ComposeMessage {
 	TextBody: string
 	ResponseMessageID: number  // If set, this was a reply or forward, based on IsForward.
 	DraftMessageID: number  // If set, previous draft message that will be removed after composing new message.
+	DraftID: number  // If set, attachments of the autosaved draft are added to the message.
+}
+
+// ResponseCompose is a prepared reply or forward of a message, for starting a
+// compose window.
+export interface ResponseCompose {
+	From: string  // Identity or account address the message was sent to, if any.
+	To?: string[] | null
+	Cc?: string[] | null
+	Bcc?: string[] | null
+	ReplyTo: string  // Reply-To of the identity, if any.
+	InReplyTo: string  // Message-ID of the message, for the In-Reply-To header.
+	References?: string[] | null  // For the References header.
+	Subject: string
+	TextBody: string  // Text with signature and quoted message, or forwarded headers and message. The cursor should be placed at EditOffset, in UTF-16 code units as used by JavaScript.
+	EditOffset: number
+	HTMLBody: string  // Quoted or forwarded message as HTML fragment, with the sanitized HTML part of the message if it has one, for composing HTML messages.
+	ForwardAttachments: ForwardAttachments  // For forwards, all attachments of the message, to include when sending.
+}
+
+// ForwardAttachments references attachments by a list of message.Part paths.
+export interface ForwardAttachments {
+	MessageID: number  // Only relevant if MessageID is not 0.
+	Paths?: (number[] | null)[] | null  // List of attachments, each path is a list of indices into the top-level message.Part.Parts.
 }
 
 // SubmitMessage is an email message to be sent to one or more recipients.
@@ -164,8 +243,11 @@ export interface SubmitMessage {
 	ReplyTo: string  // If non-empty, Reply-To header to add to message.
 	Subject: string
 	TextBody: string
+	HTMLBody: string  // If set, sent as alternative to TextBody, after sanitizing with the HTML policy from the settings.
+	InlineFiles?: InlineFile[] | null  // Images referenced from HTMLBody with "cid:" URIs.
 	Attachments?: File[] | null
 	ForwardAttachments: ForwardAttachments
+	AttachMessageID: number  // If set, this message is attached in full as message/rfc822, e.g. when forwarding as attachment.
 	IsForward: boolean
 	ResponseMessageID: number  // If set, this was a reply or forward, based on IsForward.
 	UserAgent: string  // User-Agent header added if not empty.
@@ -174,6 +256,18 @@ export interface SubmitMessage {
 	ArchiveThread: boolean  // If set, thread is archived after sending message.
 	ArchiveReferenceMailboxID: number  // If ArchiveThread is set, thread messages from this mailbox ID are moved to the archive mailbox ID. E.g. of Inbox.
 	DraftMessageID: number  // If set, draft message that will be removed after sending.
+	DraftID: number  // If set, attachments of the autosaved draft are added to the message, and the draft is removed after sending.
+	PGPSign: boolean  // Sign message with OpenPGP key of account for From address.
+	PGPEncrypt: boolean  // Encrypt message with OpenPGP keys of recipients, from account or Web Key Directory.
+	PGPPassphrase: string  // For decrypting private key for signing.
+}
+
+// InlineFile is an image for a SubmitMessage with an HTML body, referenced from
+// the HTML with a "cid:" URI, e.g. "cid:image1@example".
+export interface InlineFile {
+	ContentID: string  // Without "cid:" and "<>", e.g. "image1@example".
+	Filename: string
+	DataURI: string  // Full data of the image, with base64 encoding and including content-type.
 }
 
 // File is a new attachment (not from an existing message that is being
@@ -183,10 +277,46 @@ export interface File {
 	DataURI: string  // Full data of the attachment, with base64 encoding and including content-type.
 }
 
-// ForwardAttachments references attachments by a list of message.Part paths.
-export interface ForwardAttachments {
-	MessageID: number  // Only relevant if MessageID is not 0.
-	Paths?: (number[] | null)[] | null  // List of attachments, each path is a list of indices into the top-level message.Part.Parts.
+// SubmitResult is the result of submitting a message. If sending can be undone,
+// UndoUntil is set and the message can be canceled with MessageSubmitUndo until
+// that time.
+export interface SubmitResult {
+	QueueMsgIDs?: number[] | null  // Messages in the delivery queue, one per recipient.
+	SentMessageID: number  // Message added to the Sent mailbox, if any.
+	UndoUntil?: Date | null  // Time until delivery is held back, if undo is enabled in the settings.
+}
+
+// PGPResult is the result of verifying and/or decrypting an OpenPGP message.
+export interface PGPResult {
+	Decrypted: boolean  // Whether the message was encrypted, and has been decrypted.
+	Signed: boolean  // Whether the message has a signature, possibly inside the encrypted message.
+	SignatureValid: boolean
+	SignatureError: string  // If signature could not be verified, e.g. for an unknown key or bad signature.
+	SignerKeyID: string  // Upper case hex, from the signature.
+	SignerAddresses?: string[] | null  // Addresses of the signing key, if the key is known.
+	SignerFrom: boolean  // Whether the signing key has the From address of the message.
+	SignerKeySource: string  // "account" for a key stored in the account, "wkd" for a key from the Web Key Directory of the From address.
+	Texts?: string[] | null  // Text parts of the decrypted message.
+}
+
+// Unsubscribe is an unsubscribe request made through the webmail for a message
+// with a List-Unsubscribe header, kept as history per sender.
+export interface Unsubscribe {
+	ID: number
+	Time: Date
+	FromAddress: string  // Lower case address from the From header of the message.
+	ListID: string  // From List-Id header, may be empty.
+	MessageID: number  // Message the request was made for, may have been removed since.
+	Method: string  // "oneclick" for an HTTPS POST, or "mailto" for a message.
+	Target: string  // URL or email address.
+	Error: string  // If the request failed.
+}
+
+// BulkAction is an operation with its parameters, for MessageBulk.
+export interface BulkAction {
+	Op: BulkOp
+	MailboxID: number
+	Label: string
 }
 
 // Mailbox is collection of messages, e.g. Inbox or Sent.
@@ -213,6 +343,21 @@ export interface Mailbox {
 	Size: number  // Number of bytes for all messages.
 }
 
+// ThreadSummary is a conversation: the messages with the same ThreadID, across
+// all mailboxes.
+export interface ThreadSummary {
+	ThreadID: number
+	MessageIDs?: number[] | null  // Ordered by received time, oldest first.
+	MailboxIDs?: number[] | null  // Mailboxes with messages of the thread.
+	Unread: number  // Number of messages without \Seen flag.
+	Participants?: MessageAddress[] | null  // Unique From addresses, in order of first appearance.
+	Subject: string  // Of the latest message.
+	Snippet: string  // Preview of the text of the latest message.
+	Latest: Date  // Received time of the latest message.
+	Muted: boolean  // Whether all messages in the thread are muted.
+	Collapsed: boolean  // Whether all messages in the thread are collapsed.
+}
+
 // RecipientSecurity is a quick analysis of the security properties of delivery to
 // the recipient (domain).
 export interface RecipientSecurity {
@@ -232,6 +377,65 @@ export interface Settings {
 	ShowHTML: boolean  // Show HTML version of message by default, instead of plain text.
 	NoShowShortcuts: boolean  // If true, don't show shortcuts in webmail after mouse interaction.
 	ShowHeaders?: string[] | null  // Additional headers to display in message view. E.g. Delivered-To, User-Agent, X-Mox-Reason.
+	UndoSendSeconds: number  // Number of seconds, between 5 and 30, that messages sent from webmail are held in the queue before delivery, during which sending can be undone. Zero disables.
+	HTMLAllowedTags?: string[] | null  // HTML elements and attributes allowed in messages composed with HTML in webmail, and in HTML messages displayed in webmail, e.g. "p", "a" and "href". Others are removed. If both are empty, a default policy is used for composed messages, and displayed messages only have scripts and event handlers removed. If one is empty, its default is used.
+	HTMLAllowedAttributes?: string[] | null
+	RemoteContentProxy: boolean  // Load remote content, such as images, in HTML messages through a proxy in mox, so senders don't see the IP address and user-agent of the user. Tracking parameters are removed from the URLs.
+	Labels?: Label[] | null  // Labels with display settings, in display order. Labels are keywords on messages. Changed through dedicated webmail API calls, not by saving settings.
+	NotifyMailboxes?: string[] | null  // Mailboxes, by name, for which new messages cause a Web Push notification to browsers that subscribed through the webmail. If empty, only the Inbox.
+	NotifySenders?: string[] | null  // Senders for which new messages cause a Web Push notification, as email address or as domain starting with "@". If empty, messages from all senders cause a notification.
+}
+
+// Label is a message keyword with display settings for the webmail. A label name
+// with a slash is shown below the label before the slash, e.g. "work/project".
+export interface Label {
+	Name: string  // Keyword, lower case.
+	Color: string  // Optional, "#rrggbb".
+}
+
+// Template is a message template of the account, for composing messages in the
+// webmail, e.g. a canned response. The subject and text can contain placeholders,
+// like those of config.MessageTemplate, that are replaced when the template is
+// used. Templates shared by all accounts of a domain are in the domain
+// configuration.
+export interface Template {
+	ID: number
+	Name: string
+	Subject: string  // If empty, the subject in the compose window is left as is.
+	Text: string
+	Attachments?: TemplateAttachment[] | null
+}
+
+// TemplateAttachment is a file added as attachment when composing a message with
+// a template.
+export interface TemplateAttachment {
+	Filename: string
+	DataURI: string  // Full data of the attachment, with base64 encoding and including content-type.
+}
+
+// SharedTemplate is a message template shared by a domain the account has an
+// address in, configured by the admin.
+export interface SharedTemplate {
+	Domain: string  // Domain name, unicode.
+	Name: string
+	Subject: string
+	Text: string
+}
+
+// TemplateRef references a template: an account template by ID, or a template
+// shared by a domain by its domain and name.
+export interface TemplateRef {
+	ID: number
+	Domain: string  // Unicode.
+	Name: string
+}
+
+// ComposeTemplate is a template with placeholders replaced, for composing a
+// message.
+export interface ComposeTemplate {
+	Subject: string  // If empty, the subject should be left as is.
+	Text: string
+	Attachments?: File[] | null
 }
 
 export interface Ruleset {
@@ -248,55 +452,72 @@ export interface Ruleset {
 	ListAllowDNSDomain: Domain
 }
 
-// EventStart is the first message sent on an SSE connection, giving the client
-// basic data to populate its UI. After this event, messages will follow quickly in
-// an EventViewMsgs event.
-export interface EventStart {
-	SSEID: number
-	LoginAddress: MessageAddress
-	Addresses?: MessageAddress[] | null
-	DomainAddressConfigs?: { [key: string]: DomainAddressConfig }  // ASCII domain to address config.
-	MailboxName: string
-	Mailboxes?: Mailbox[] | null
-	RejectsMailbox: string
-	Settings: Settings
-	AccountPath: string  // If nonempty, the path on same host to webaccount interface.
-	Version: string
+// Rule is a filter rule managed through the webmail, applied to incoming
+// messages after delivery, and on request to existing messages in a mailbox.
+// All non-empty conditions must match for the actions to be applied. Text
+// conditions are case-insensitive substring matches.
+export interface Rule {
+	ID: number
+	Position: number  // Rules are evaluated in ascending position.
+	Name: string
+	Disabled: boolean
+	From: string  // Conditions.; Address or display name in From header.
+	To: string  // Address or display name in To or Cc header.
+	SubjectContains: string
+	HasAttachment: boolean
+	ListID: string  // In List-Id header.
+	Mailbox: string  // Actions.; Move to mailbox with this name.
+	MarkRead: boolean  // Set the \Seen flag.
+	ForwardTo?: string[] | null  // Forward as attachment to these addresses. Only for incoming messages.
+	Delete: boolean  // Move to mailbox with Trash special-use role.
+	Stop: boolean  // Don't evaluate later rules if this rule matches.
+}
+
+// SavedSearch is a named search, shown as virtual folder in the mailbox list.
+export interface SavedSearch {
+	ID: number
+	Name: string
+	Search: string  // As entered in the search bar, for opening the search.
+	Filter: Filter
+	NotFilter: NotFilter
+	Total: number  // Number of matching messages, and how many of them are unseen. Set by the server, ignored when saving.
+	Unseen: number
 }
 
-// DomainAddressConfig has the address (localpart) configuration for a domain, so
-// the webmail client can decide if an address matches the addresses of the
-// account.
-export interface DomainAddressConfig {
-	LocalpartCatchallSeparators?: string[] | null  // Can be empty.
-	LocalpartCaseSensitive: boolean
+// Draft is the autosaved state of a message being composed.
+export interface Draft {
+	ID: number
+	Version: number  // Incremented by the server with each save. Saving a draft with another version than the current version fails with error code "user:draftConflict": the draft was changed from another device or browser window.
+	Updated: Date  // Set by the server.
+	Message: ComposeMessage  // Compose state. DraftMessageID is the draft message in the Drafts mailbox this compose started from, if any. DraftID is ignored.
+	Attachments?: DraftAttachment[] | null  // Uploaded attachments. Set by the server, ignored when saving.
 }
 
-// EventViewErr indicates an error during a query for messages. The request is
-// aborted, no more request-related messages will be sent until the next request.
-export interface EventViewErr {
-	ViewID: number
-	RequestID: number
-	Err: string  // To be displayed in client.
+// DraftAttachment is an attachment uploaded for a draft. It is added to the
+// message when it is sent or saved to the Drafts mailbox.
+export interface DraftAttachment {
+	ID: number
+	Filename: string
+	ContentType: string
+	Size: number  // Decoded size.
 }
 
-// EventViewReset indicates that a request for the next set of messages in a few
-// could not be fulfilled, e.g. because the anchor message does not exist anymore.
-// The client should clear its list of messages. This can happen before
-// EventViewMsgs events are sent.
-export interface EventViewReset {
-	ViewID: number
-	RequestID: number
+// Delegation gives another account access to mailboxes of an account.
+export interface Delegation {
+	Account: string
+	Mailboxes?: string[] | null
+	Write: boolean
+	Send: string
 }
 
-// EventViewMsgs contains messages for a view, possibly a continuation of an
-// earlier list of messages.
-export interface EventViewMsgs {
-	ViewID: number
-	RequestID: number
-	MessageItems?: (MessageItem[] | null)[] | null  // If empty, this was the last message for the request. If non-empty, a list of thread messages. Each with the first message being the reason this thread is included and can be used as AnchorID in followup requests. If the threading mode is "off" in the query, there will always be only a single message. If a thread is sent, all messages in the thread are sent, including those that don't match the query (e.g. from another mailbox). Threads can be displayed based on the ThreadParentIDs field, with possibly slightly different display based on field ThreadMissingLink.
-	ParsedMessage?: ParsedMessage | null  // If set, will match the target page.DestMessageID from the request.
-	ViewEnd: boolean  // If set, there are no more messages in this view at this moment. Messages can be added, typically via Change messages, e.g. for new deliveries.
+// DelegatedAccess is access to mailboxes of another account, given to the
+// account of the session.
+export interface DelegatedAccess {
+	Account: string  // Account that gave access.
+	Mailboxes?: string[] | null
+	Write: boolean
+	Send: string  // Empty, "as" or "onbehalf".
+	Addresses?: string[] | null  // Addresses of the account, for sending messages, only if Send is set.
 }
 
 // MessageItem is sent by queries, it has derived information analyzed from
@@ -308,8 +529,13 @@ export interface MessageItem {
 	Attachments?: Attachment[] | null
 	IsSigned: boolean
 	IsEncrypted: boolean
+	SMIME?: SMIMEStatus | null  // For messages with an S/MIME signature.
+	Calendar?: CalendarInvite | null  // From the first text/calendar part, e.g. a meeting invitation.
+	Unsubscribe?: ListUnsubscribe | null  // From List-Unsubscribe header, for mailing lists.
+	Auth?: AuthResults | null  // From headers added by mox during delivery. Nil for messages not delivered over SMTP.
 	MatchQuery: boolean  // If message does not match query, it can still be included because of threading.
 	MoreHeaders?: (string[] | null)[] | null  // All headers from store.Settings.ShowHeaders that are present.
+	SenderWarnings?: SenderWarning[] | null  // Reasons to be suspicious of the sender, e.g. a domain resembling that of a contact. Only set for messages in views.
 }
 
 // Message stored in database and per-message file on disk.
@@ -354,6 +580,8 @@ export interface Message {
 	DKIMDomains?: string[] | null  // Domains with verified DKIM signatures. Unicode string. For forwarded messages, a DKIM domain that matched a ruleset's verified domain is left out, but included in OrigDKIMDomains.
 	OrigEHLODomain: string  // For forwarded messages,
 	OrigDKIMDomains?: string[] | null
+	Auth?: MessageAuth | null  // Results of authentication checks during delivery over SMTP, nil for messages not delivered over SMTP (e.g. sent, imported), or delivered before the results were stored.
+	ReputationJunk?: boolean | null  // Junk status of a message delivered over SMTP as last accounted for in the server-wide sender reputation. Nil if no junk status was set yet.
 	MessageID: string  // Canonicalized Message-Id, always lower-case and normalized quoting, without <>'s. Empty if missing. Used for matching message threads, and to prevent duplicate reject delivery.
 	SubjectBase: string  // For matching threads in case there is no References/In-Reply-To header. It is lower-cased, white-space collapsed, mailing list tags and re/fwd tags removed.
 	MessageHash?: string | null  // Hash of message. For rejects delivery in case there is no Message-ID, only set when delivered as reject.
@@ -381,10 +609,52 @@ export interface Message {
 	Size: number
 	TrainedJunk?: boolean | null  // If nil, no training done yet. Otherwise, true is trained as junk, false trained as nonjunk.
 	MsgPrefix?: string | null  // Typically holds received headers and/or header separator.
+	StoredSize: number  // Size of the on-disk message file, excluding MsgPrefix. Smaller than Size minus the length of MsgPrefix if the file is compressed. Zero if the message was added while MessageCompression wasn't configured, and the message hasn't been compressed yet.
+	Compressed: boolean  // Whether the on-disk message file is compressed. Never determined from the file contents, a message can contain anything.
 	Preview?: string | null  // If non-nil, a preview of the message based on text and/or html parts of the message. Used in the webmail and IMAP PREVIEW extension. If non-nil, it is empty if no preview could be created, or the message has not textual content or couldn't be parsed. Previews are typically created when delivering a message, but not when importing messages, for speed. Previews are generated on first request (in the webmail, or through the IMAP fetch attribute "PREVIEW" (without "LAZY")), and stored with the message at that time. The preview is at most 256 characters (can be more bytes), with detected quoted text replaced with "[...]". Previews typically end with a newline, callers may want to strip whitespace.
 	ParsedBuf?: string | null  // ParsedBuf message structure. Currently saved as JSON of message.Part because bstore wasn't able to store recursive types when this was implemented. Created when first needed, and saved in the database. todo: once replaced with non-json storage, remove date fixup in ../message/part.go.
 }
 
+// MessageAuth holds the results of the authentication checks done during delivery
+// over SMTP, as also added to the message in the Authentication-Results header.
+// Stored with the message so they can be used without parsing headers. Domains are
+// unicode strings.
+export interface MessageAuth {
+	IPRev: string  // Reverse IP lookup: "pass", "fail", "temperror", "permerror".
+	IPRevAuthentic: boolean  // Whether the lookups were DNSSEC-verified.
+	SPF: string  // "pass", "fail", "softfail", "neutral", "none", "temperror", "permerror".
+	SPFIdentity: string  // "mailfrom" or "helo". Empty if the EHLO hostname was an IP address.
+	SPFDomain: string  // Domain the SPF record was evaluated for.
+	SPFAuthentic: boolean
+	DKIM?: MessageAuthDKIM[] | null  // One per DKIM-Signature header. Empty if there were none.
+	DMARC: string  // "pass", "fail", "none", "temperror", "permerror".
+	DMARCDomain: string  // Domain of message From header. Empty if there was no From address.
+	DMARCPolicy: string  // Published policy: "none", "quarantine", "reject". Empty without record.
+	DMARCAuthentic: boolean  // Whether the DMARC record was DNSSEC-verified.
+	DMARCOverrides?: string[] | null  // Local policy overrides, e.g. "mailing_list", "sampled_out", "trusted_forwarder".
+	ARCSealer: string  // For a DMARC failure overridden because the message was forwarded by a trusted ARC sealer, the domain of the sealer.
+	BIMI: string  // For messages passing DMARC, e.g. "pass", "none", "fail". Empty if not evaluated.
+	BIMIDomain: string  // Domain of the BIMI record, can be the organizational domain of DMARCDomain.
+	DNSBLs?: MessageAuthDNSBL[] | null  // DNS blocklists the remote IP was checked against. Blocklists are only checked during junk analysis for senders without reputation.
+}
+
+// MessageAuthDKIM is the verification result for a DKIM-Signature header.
+export interface MessageAuthDKIM {
+	Status: string  // "pass", "fail", "neutral", "none", "policy", "temperror", "permerror".
+	Domain: string  // Signing domain, empty if the signature could not be parsed.
+	Selector: string
+	Algorithm: string  // E.g. "rsa-sha256" or "ed25519-sha256".
+	Identity: string  // Optional agent or user identifier, "i=" in the signature.
+	Authentic: boolean  // Whether the DKIM DNS record was DNSSEC-verified.
+	Error: string  // Verification error, if any.
+}
+
+// MessageAuthDNSBL is the result of a DNS blocklist check of the remote IP.
+export interface MessageAuthDNSBL {
+	Zone: string
+	Status: string  // "pass" for not listed, "fail" for listed, "temperror", or "skipped" if the blocklist is unhealthy.
+}
+
 // MessageEnvelope is like message.Envelope, as used in message.Part, but including
 // unicode host names for IDNA names.
 export interface MessageEnvelope {
@@ -408,9 +678,163 @@ export interface Attachment {
 	Part: Part
 }
 
+// SMIMEStatus is the result of verifying the S/MIME signature of a message.
+export interface SMIMEStatus {
+	Valid: boolean  // Signature is valid and the certificate is trusted.
+	Error: string  // If not valid, e.g. for a modified message or unknown certificate authority.
+	SignerAddresses?: string[] | null  // Email addresses in the certificate of the signer.
+	SignerFrom: boolean  // Whether the certificate is for the From address of the message.
+	Fingerprint: string  // Of the public key of the signer, for pinning.
+}
+
+// CalendarInvite is an event from a text/calendar part of a message, typically a
+// meeting invitation (method REQUEST), or a reply to an invitation.
+export interface CalendarInvite {
+	Path?: number[] | null  // Of the text/calendar part in the message.
+	Method: string  // Upper case, e.g. REQUEST, REPLY, CANCEL. Empty for calendar data that isn't a scheduling message.
+	UID: string
+	Sequence: number
+	Summary: string
+	Location: string
+	Description: string
+	Start: Date
+	End?: Date | null
+	AllDay: boolean  // If set, Start and End are dates, at midnight UTC.
+	Organizer: CalendarAttendee
+	Attendees?: CalendarAttendee[] | null
+}
+
+// CalendarAttendee is the organizer or an attendee of an event.
+export interface CalendarAttendee {
+	Name: string
+	Address: string  // From the "mailto:" URI, empty for other URIs.
+	Status: string  // Participation status, e.g. NEEDS-ACTION, ACCEPTED, TENTATIVE, DECLINED. Empty for the organizer.
+	Role: string  // E.g. REQ-PARTICIPANT, OPT-PARTICIPANT, CHAIR.
+}
+
+// ListUnsubscribe has the methods from the List-Unsubscribe header of a message,
+// RFC 2369, for unsubscribing from a mailing list.
+export interface ListUnsubscribe {
+	URL: string  // HTTP(S) URL, for one-click unsubscribe or for opening in a browser.
+	Mailto: string  // mailto URI, for sending an unsubscribe message.
+	OneClick: boolean  // If URL is HTTPS and List-Unsubscribe-Post requests one-click unsubscribe, RFC 8058. Only for messages with a valid DKIM signature, as required by the RFC.
+}
+
+// AuthResults summarizes the message authentication checks done by mox when the
+// message was delivered, for showing pass/fail indicators. Only the headers mox
+// added during delivery are used, headers in the message itself could have been
+// added by anyone.
+export interface AuthResults {
+	IPRev: AuthCheck  // Reverse DNS lookup of the remote IP, without domain.
+	SPF: AuthCheck  // With MAIL FROM domain, or EHLO domain if MAIL FROM was empty.
+	DKIM?: AuthCheck[] | null  // One per DKIM-Signature header, with signing domain.
+	DMARC: AuthCheck  // With domain of message From header. Result empty if domain has no DMARC record.
+	BIMI: AuthCheck  // With domain of BIMI record. Result empty if not evaluated.
+	BIMILogoURL: string  // For messages that passed BIMI evaluation, the URL of the logo of the sender domain, and the path relative to the webmail for loading it through mox.
+	BIMILogoPath: string
+	Reason: string  // Reason for the delivery decision, e.g. "junk-content" or "dmarc-policy" for messages delivered to the Junk mailbox, with human-readable details.
+	ReasonDetails?: string[] | null
+}
+
+// AuthCheck is the result of an authentication check.
+export interface AuthCheck {
+	Result: string  // E.g. "pass", "fail", "softfail", "none", "temperror".
+	Domain: string
+}
+
+// SenderWarning is a reason to be suspicious of the sender of a message.
+export interface SenderWarning {
+	Kind: SenderWarningKind
+	Similar: string  // Known address or domain the sender resembles, or the Reply-To address.
+	Text: string  // Human-readable explanation.
+}
+
+// EventStart is the first message sent on an SSE connection, giving the client
+// basic data to populate its UI. After this event, messages will follow quickly in
+// an EventViewMsgs event.
+export interface EventStart {
+	SSEID: number
+	LoginAddress: MessageAddress
+	Addresses?: MessageAddress[] | null
+	DomainAddressConfigs?: { [key: string]: DomainAddressConfig }  // ASCII domain to address config.
+	MailboxName: string
+	Mailboxes?: Mailbox[] | null
+	RejectsMailbox: string
+	Settings: Settings
+	Identities?: Identity[] | null  // For composing messages, with From address, display name and signature.
+	PGPAddresses?: string[] | null  // Addresses with an OpenPGP private key, for signing and encrypting when composing.
+	Delegated?: DelegatedAccess[] | null  // Access to mailboxes given by other accounts, for opening their mailboxes and sending messages.
+	SavedSearches?: SavedSearch[] | null  // Shown with the mailboxes. Updated counts are sent in EventSavedSearches.
+	AccountPath: string  // If nonempty, the path on same host to webaccount interface.
+	QuotaWarning: string  // If nonempty, the account is near its quota or over its soft limit, shown to the user.
+	Impersonation?: Impersonation | null  // If set, an admin is acting as the account, shown to the user.
+	Version: string
+	SearchIndexSalt: string  // If nonempty, the account has a hashed full-text search index, and this is the base64-encoded salt. Search words must be sent as WordHashes, the hex-encoded first 16 bytes of the HMAC-SHA256 keyed with the salt of each lower-cased run of letters and digits. Runs of more than 64 characters are hashed as parts of 64 characters, starting every 32 characters. Only whole words can be searched for.
+	Resumed: boolean  // Whether the view from the request was resumed. If so, no messages are sent for the request, only changes since the last event ID from the resume parameter, in EventViewChanges.
+}
+
+// DomainAddressConfig has the address (localpart) configuration for a domain, so
+// the webmail client can decide if an address matches the addresses of the
+// account.
+export interface DomainAddressConfig {
+	LocalpartCatchallSeparators?: string[] | null  // Can be empty.
+	LocalpartCaseSensitive: boolean
+}
+
+// Identity is an address with display name and signature an account can send
+// messages as in the webmail.
+export interface Identity {
+	Address: string
+	DisplayName: string
+	Signature?: string[] | null
+	SignatureHTML: string
+	ReplyQuoting: string
+	ReplyTo: string
+	Default: boolean
+}
+
+// Impersonation is a session of an admin acting as the account.
+export interface Impersonation {
+	By: string  // Admin, e.g. "(admin)" or "(admin:<user>)", as in the audit log.
+	ReadOnly: boolean  // If set, no changes can be made.
+	End: Date  // After which the session cannot be used.
+}
+
+// EventViewErr indicates an error during a query for messages. The request is
+// aborted, no more request-related messages will be sent until the next request.
+export interface EventViewErr {
+	ViewID: number
+	RequestID: number
+	Err: string  // To be displayed in client.
+}
+
+// EventViewReset indicates that a request for the next set of messages in a few
+// could not be fulfilled, e.g. because the anchor message does not exist anymore.
+// The client should clear its list of messages. This can happen before
+// EventViewMsgs events are sent.
+export interface EventViewReset {
+	ViewID: number
+	RequestID: number
+}
+
+// EventViewMsgs contains messages for a view, possibly a continuation of an
+// earlier list of messages.
+export interface EventViewMsgs {
+	ViewID: number
+	RequestID: number
+	MessageItems?: (MessageItem[] | null)[] | null  // If empty, this was the last message for the request. If non-empty, a list of thread messages. Each with the first message being the reason this thread is included and can be used as AnchorID in followup requests. If the threading mode is "off" in the query, there will always be only a single message. If a thread is sent, all messages in the thread are sent, including those that don't match the query (e.g. from another mailbox). Threads can be displayed based on the ThreadParentIDs field, with possibly slightly different display based on field ThreadMissingLink.
+	ParsedMessage?: ParsedMessage | null  // If set, will match the target page.DestMessageID from the request.
+	ViewEnd: boolean  // If set, there are no more messages in this view at this moment. Messages can be added, typically via Change messages, e.g. for new deliveries.
+}
+
 // EventViewChanges contain one or more changes relevant for the client, either
 // with new mailbox total/unseen message counts, or messages added/removed/modified
-// (flags) for the current view.
+// (flags) for one of the views. Changes to mailboxes and threads are not specific
+// to a view, and are sent with ViewID 0.
+// 
+// Bursts of changes are combined, with only the last flags of a message and the
+// last counts of a mailbox. Events with changes have an SSE "id" field with the
+// modseq of the account, for resuming after reconnecting.
 export interface EventViewChanges {
 	ViewID: number
 	Changes?: (any[] | null)[] | null  // The first field of [2]any is a string, the second of the Change types below.
@@ -531,6 +955,30 @@ export interface ChangeMailboxKeywords {
 	Keywords?: string[] | null
 }
 
+// EventSavedSearches has the saved searches of the account with up to date
+// message counts. Sent after changes to messages that modify the counts.
+export interface EventSavedSearches {
+	SavedSearches?: SavedSearch[] | null
+}
+
+// EventBulkProgress is sent while applying an operation to all messages matching
+// a query, see MessageBulk.
+export interface EventBulkProgress {
+	Op: BulkOp
+	Done: number  // Number of messages processed.
+	Total: number
+}
+
+// ViewResume is passed as "resume" parameter by a client reconnecting, to resume
+// its view instead of fetching all messages again. If the changes since the last
+// event cannot be determined, e.g. because history of removed messages has been
+// cleaned up, the request is executed as a new query instead.
+export interface ViewResume {
+	LastEventID: number  // The "id" of the last event received, the modseq of the account.
+	LastMessageReceived: Date  // Of the last message in the view. New messages after it are out of range.
+	ViewEnd: boolean  // Whether the client has all messages of the view.
+}
+
 // ModSeq represents a modseq as stored in the database. ModSeq 0 in the
 // database is sent to the client as 1, because modseq 0 is special in IMAP.
 // ModSeq coming from the client are of type int64.
@@ -583,6 +1031,23 @@ export enum ViewMode {
 	ModeHTMLExt = "htmlext",  // HTML with external resources.
 }
 
+// ResponseMode is how to respond to a message.
+export enum ResponseMode {
+	ResponseReply = "reply",  // To the sender, or Reply-To.
+	ResponseReplyAll = "replyall",  // To the sender and all other recipients.
+	ResponseReplyList = "replylist",  // To the mailing list, from List-Post header.
+	ResponseForward = "forward",
+}
+
+// BulkOp is an operation applied to all messages matching a query.
+export enum BulkOp {
+	BulkMarkRead = "markread",
+	BulkMarkUnread = "markunread",
+	BulkMove = "move",  // To BulkAction.MailboxID.
+	BulkLabel = "label",  // Add BulkAction.Label.
+	BulkDelete = "delete",
+}
+
 // SecurityResult indicates whether a security feature is supported.
 export enum SecurityResult {
 	SecurityResultError = "error",
@@ -607,39 +1072,88 @@ export enum Quoting {
 // Localparts are in Unicode NFC.
 export type Localpart = string
 
-export const structTypes: {[typename: string]: boolean} = {"Address":true,"Attachment":true,"ChangeMailboxAdd":true,"ChangeMailboxCounts":true,"ChangeMailboxKeywords":true,"ChangeMailboxRemove":true,"ChangeMailboxRename":true,"ChangeMailboxSpecialUse":true,"ChangeMsgAdd":true,"ChangeMsgFlags":true,"ChangeMsgRemove":true,"ChangeMsgThread":true,"ComposeMessage":true,"Domain":true,"DomainAddressConfig":true,"Envelope":true,"EventStart":true,"EventViewChanges":true,"EventViewErr":true,"EventViewMsgs":true,"EventViewReset":true,"File":true,"Filter":true,"Flags":true,"ForwardAttachments":true,"FromAddressSettings":true,"Mailbox":true,"Message":true,"MessageAddress":true,"MessageEnvelope":true,"MessageItem":true,"NotFilter":true,"Page":true,"ParsedMessage":true,"Part":true,"Query":true,"RecipientSecurity":true,"Request":true,"Ruleset":true,"Settings":true,"SpecialUse":true,"SubmitMessage":true}
-export const stringsTypes: {[typename: string]: boolean} = {"AttachmentType":true,"CSRFToken":true,"Localpart":true,"Quoting":true,"SecurityResult":true,"ThreadMode":true,"ViewMode":true}
+// SenderWarningKind is the heuristic that triggered a sender warning.
+export enum SenderWarningKind {
+	// Display name of From matches a contact, but the address is not one of the
+	// contact's addresses.
+	SenderWarningDisplayName = "displayname",
+	// Domain of From looks like, but is not, a domain of the account or a known
+	// sender: differs by a character or two, or looks the same when rendered.
+	SenderWarningLookalike = "lookalike",
+	// Replies go to an unknown address in another organization than the From
+	// address.
+	SenderWarningReplyTo = "replyto",
+}
+
+export const structTypes: {[typename: string]: boolean} = {"Address":true,"Attachment":true,"AuthCheck":true,"AuthResults":true,"BulkAction":true,"CalendarAttendee":true,"CalendarInvite":true,"ChangeMailboxAdd":true,"ChangeMailboxCounts":true,"ChangeMailboxKeywords":true,"ChangeMailboxRemove":true,"ChangeMailboxRename":true,"ChangeMailboxSpecialUse":true,"ChangeMsgAdd":true,"ChangeMsgFlags":true,"ChangeMsgRemove":true,"ChangeMsgThread":true,"ComposeMessage":true,"ComposeTemplate":true,"DelegatedAccess":true,"Delegation":true,"Domain":true,"DomainAddressConfig":true,"Draft":true,"DraftAttachment":true,"Envelope":true,"EventBulkProgress":true,"EventSavedSearches":true,"EventStart":true,"EventViewChanges":true,"EventViewErr":true,"EventViewMsgs":true,"EventViewReset":true,"File":true,"Filter":true,"Flags":true,"ForwardAttachments":true,"FromAddressSettings":true,"Identity":true,"Impersonation":true,"InlineFile":true,"Label":true,"ListUnsubscribe":true,"Mailbox":true,"Message":true,"MessageAddress":true,"MessageAuth":true,"MessageAuthDKIM":true,"MessageAuthDNSBL":true,"MessageEnvelope":true,"MessageItem":true,"MessageJunkVerdict":true,"MessageJunkWord":true,"NotFilter":true,"PGPResult":true,"Page":true,"ParsedMessage":true,"Part":true,"PasskeyAssertion":true,"PasskeyGetOptions":true,"Query":true,"RecipientSecurity":true,"Request":true,"ResponseCompose":true,"Rule":true,"Ruleset":true,"SMIMECertificate":true,"SMIMEStatus":true,"SavedSearch":true,"SenderWarning":true,"Settings":true,"SharedTemplate":true,"SpecialUse":true,"SubmitMessage":true,"SubmitResult":true,"Template":true,"TemplateAttachment":true,"TemplateRef":true,"ThreadSummary":true,"Unsubscribe":true,"ViewResume":true}
+export const stringsTypes: {[typename: string]: boolean} = {"AttachmentType":true,"BulkOp":true,"CSRFToken":true,"Localpart":true,"Quoting":true,"ResponseMode":true,"SecurityResult":true,"SenderWarningKind":true,"ThreadMode":true,"ViewMode":true}
 export const intsTypes: {[typename: string]: boolean} = {"ModSeq":true,"UID":true,"Validation":true}
 export const types: TypenameMap = {
-	"Request": {"Name":"Request","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"SSEID","Docs":"","Typewords":["int64"]},{"Name":"ViewID","Docs":"","Typewords":["int64"]},{"Name":"Cancel","Docs":"","Typewords":["bool"]},{"Name":"Query","Docs":"","Typewords":["Query"]},{"Name":"Page","Docs":"","Typewords":["Page"]}]},
+	"PasskeyGetOptions": {"Name":"PasskeyGetOptions","Docs":"","Fields":[{"Name":"Challenge","Docs":"","Typewords":["nullable","string"]},{"Name":"RPID","Docs":"","Typewords":["string"]},{"Name":"Timeout","Docs":"","Typewords":["int32"]}]},
+	"PasskeyAssertion": {"Name":"PasskeyAssertion","Docs":"","Fields":[{"Name":"CredentialID","Docs":"","Typewords":["nullable","string"]},{"Name":"ClientDataJSON","Docs":"","Typewords":["nullable","string"]},{"Name":"AuthenticatorData","Docs":"","Typewords":["nullable","string"]},{"Name":"Signature","Docs":"","Typewords":["nullable","string"]}]},
+	"Request": {"Name":"Request","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"SSEID","Docs":"","Typewords":["int64"]},{"Name":"ViewID","Docs":"","Typewords":["int64"]},{"Name":"Cancel","Docs":"","Typewords":["bool"]},{"Name":"KeepViews","Docs":"","Typewords":["bool"]},{"Name":"Query","Docs":"","Typewords":["Query"]},{"Name":"Page","Docs":"","Typewords":["Page"]}]},
 	"Query": {"Name":"Query","Docs":"","Fields":[{"Name":"OrderAsc","Docs":"","Typewords":["bool"]},{"Name":"Threading","Docs":"","Typewords":["ThreadMode"]},{"Name":"Filter","Docs":"","Typewords":["Filter"]},{"Name":"NotFilter","Docs":"","Typewords":["NotFilter"]}]},
-	"Filter": {"Name":"Filter","Docs":"","Fields":[{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"MailboxChildrenIncluded","Docs":"","Typewords":["bool"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"Words","Docs":"","Typewords":["[]","string"]},{"Name":"From","Docs":"","Typewords":["[]","string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Oldest","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Newest","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Subject","Docs":"","Typewords":["[]","string"]},{"Name":"Attachments","Docs":"","Typewords":["AttachmentType"]},{"Name":"Labels","Docs":"","Typewords":["[]","string"]},{"Name":"Headers","Docs":"","Typewords":["[]","[]","string"]},{"Name":"SizeMin","Docs":"","Typewords":["int64"]},{"Name":"SizeMax","Docs":"","Typewords":["int64"]}]},
-	"NotFilter": {"Name":"NotFilter","Docs":"","Fields":[{"Name":"Words","Docs":"","Typewords":["[]","string"]},{"Name":"From","Docs":"","Typewords":["[]","string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Subject","Docs":"","Typewords":["[]","string"]},{"Name":"Attachments","Docs":"","Typewords":["AttachmentType"]},{"Name":"Labels","Docs":"","Typewords":["[]","string"]}]},
+	"Filter": {"Name":"Filter","Docs":"","Fields":[{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"MailboxChildrenIncluded","Docs":"","Typewords":["bool"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"Words","Docs":"","Typewords":["[]","string"]},{"Name":"WordHashes","Docs":"","Typewords":["[]","string"]},{"Name":"From","Docs":"","Typewords":["[]","string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Oldest","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Newest","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"Subject","Docs":"","Typewords":["[]","string"]},{"Name":"Attachments","Docs":"","Typewords":["AttachmentType"]},{"Name":"Labels","Docs":"","Typewords":["[]","string"]},{"Name":"Headers","Docs":"","Typewords":["[]","[]","string"]},{"Name":"SizeMin","Docs":"","Typewords":["int64"]},{"Name":"SizeMax","Docs":"","Typewords":["int64"]}]},
+	"NotFilter": {"Name":"NotFilter","Docs":"","Fields":[{"Name":"Words","Docs":"","Typewords":["[]","string"]},{"Name":"WordHashes","Docs":"","Typewords":["[]","string"]},{"Name":"From","Docs":"","Typewords":["[]","string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Subject","Docs":"","Typewords":["[]","string"]},{"Name":"Attachments","Docs":"","Typewords":["AttachmentType"]},{"Name":"Labels","Docs":"","Typewords":["[]","string"]}]},
 	"Page": {"Name":"Page","Docs":"","Fields":[{"Name":"AnchorMessageID","Docs":"","Typewords":["int64"]},{"Name":"Count","Docs":"","Typewords":["int32"]},{"Name":"DestMessageID","Docs":"","Typewords":["int64"]}]},
-	"ParsedMessage": {"Name":"ParsedMessage","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Part","Docs":"","Typewords":["Part"]},{"Name":"Headers","Docs":"","Typewords":["{}","[]","string"]},{"Name":"ViewMode","Docs":"","Typewords":["ViewMode"]},{"Name":"Texts","Docs":"","Typewords":["[]","string"]},{"Name":"HasHTML","Docs":"","Typewords":["bool"]},{"Name":"ListReplyAddress","Docs":"","Typewords":["nullable","MessageAddress"]},{"Name":"TextPaths","Docs":"","Typewords":["[]","[]","int32"]},{"Name":"HTMLPath","Docs":"","Typewords":["[]","int32"]}]},
+	"ParsedMessage": {"Name":"ParsedMessage","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Part","Docs":"","Typewords":["Part"]},{"Name":"Headers","Docs":"","Typewords":["{}","[]","string"]},{"Name":"ViewMode","Docs":"","Typewords":["ViewMode"]},{"Name":"Texts","Docs":"","Typewords":["[]","string"]},{"Name":"HasHTML","Docs":"","Typewords":["bool"]},{"Name":"ListReplyAddress","Docs":"","Typewords":["nullable","MessageAddress"]},{"Name":"TextPaths","Docs":"","Typewords":["[]","[]","int32"]},{"Name":"HTMLPath","Docs":"","Typewords":["[]","int32"]},{"Name":"SMIMECertificate","Docs":"","Typewords":["nullable","SMIMECertificate"]},{"Name":"SMIMEPinned","Docs":"","Typewords":["string"]}]},
 	"Part": {"Name":"Part","Docs":"","Fields":[{"Name":"BoundaryOffset","Docs":"","Typewords":["int64"]},{"Name":"HeaderOffset","Docs":"","Typewords":["int64"]},{"Name":"BodyOffset","Docs":"","Typewords":["int64"]},{"Name":"EndOffset","Docs":"","Typewords":["int64"]},{"Name":"RawLineCount","Docs":"","Typewords":["int64"]},{"Name":"DecodedSize","Docs":"","Typewords":["int64"]},{"Name":"MediaType","Docs":"","Typewords":["string"]},{"Name":"MediaSubType","Docs":"","Typewords":["string"]},{"Name":"ContentTypeParams","Docs":"","Typewords":["{}","string"]},{"Name":"ContentID","Docs":"","Typewords":["nullable","string"]},{"Name":"ContentDescription","Docs":"","Typewords":["nullable","string"]},{"Name":"ContentTransferEncoding","Docs":"","Typewords":["nullable","string"]},{"Name":"ContentDisposition","Docs":"","Typewords":["nullable","string"]},{"Name":"ContentMD5","Docs":"","Typewords":["nullable","string"]},{"Name":"ContentLanguage","Docs":"","Typewords":["nullable","string"]},{"Name":"ContentLocation","Docs":"","Typewords":["nullable","string"]},{"Name":"Envelope","Docs":"","Typewords":["nullable","Envelope"]},{"Name":"Parts","Docs":"","Typewords":["[]","Part"]},{"Name":"Message","Docs":"","Typewords":["nullable","Part"]}]},
 	"Envelope": {"Name":"Envelope","Docs":"","Fields":[{"Name":"Date","Docs":"","Typewords":["timestamp"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["[]","Address"]},{"Name":"Sender","Docs":"","Typewords":["[]","Address"]},{"Name":"ReplyTo","Docs":"","Typewords":["[]","Address"]},{"Name":"To","Docs":"","Typewords":["[]","Address"]},{"Name":"CC","Docs":"","Typewords":["[]","Address"]},{"Name":"BCC","Docs":"","Typewords":["[]","Address"]},{"Name":"InReplyTo","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]}]},
 	"Address": {"Name":"Address","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"User","Docs":"","Typewords":["string"]},{"Name":"Host","Docs":"","Typewords":["string"]}]},
 	"MessageAddress": {"Name":"MessageAddress","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"User","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["Domain"]}]},
 	"Domain": {"Name":"Domain","Docs":"","Fields":[{"Name":"ASCII","Docs":"","Typewords":["string"]},{"Name":"Unicode","Docs":"","Typewords":["string"]}]},
+	"SMIMECertificate": {"Name":"SMIMECertificate","Docs":"","Fields":[{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Issuer","Docs":"","Typewords":["string"]},{"Name":"SerialNumber","Docs":"","Typewords":["string"]},{"Name":"NotBefore","Docs":"","Typewords":["timestamp"]},{"Name":"NotAfter","Docs":"","Typewords":["timestamp"]},{"Name":"Chain","Docs":"","Typewords":["[]","string"]}]},
 	"FromAddressSettings": {"Name":"FromAddressSettings","Docs":"","Fields":[{"Name":"FromAddress","Docs":"","Typewords":["string"]},{"Name":"ViewMode","Docs":"","Typewords":["ViewMode"]}]},
-	"ComposeMessage": {"Name":"ComposeMessage","Docs":"","Fields":[{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Cc","Docs":"","Typewords":["[]","string"]},{"Name":"Bcc","Docs":"","Typewords":["[]","string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"TextBody","Docs":"","Typewords":["string"]},{"Name":"ResponseMessageID","Docs":"","Typewords":["int64"]},{"Name":"DraftMessageID","Docs":"","Typewords":["int64"]}]},
-	"SubmitMessage": {"Name":"SubmitMessage","Docs":"","Fields":[{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Cc","Docs":"","Typewords":["[]","string"]},{"Name":"Bcc","Docs":"","Typewords":["[]","string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"TextBody","Docs":"","Typewords":["string"]},{"Name":"Attachments","Docs":"","Typewords":["[]","File"]},{"Name":"ForwardAttachments","Docs":"","Typewords":["ForwardAttachments"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"ResponseMessageID","Docs":"","Typewords":["int64"]},{"Name":"UserAgent","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureRelease","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"ArchiveThread","Docs":"","Typewords":["bool"]},{"Name":"ArchiveReferenceMailboxID","Docs":"","Typewords":["int64"]},{"Name":"DraftMessageID","Docs":"","Typewords":["int64"]}]},
-	"File": {"Name":"File","Docs":"","Fields":[{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"DataURI","Docs":"","Typewords":["string"]}]},
+	"MessageJunkVerdict": {"Name":"MessageJunkVerdict","Docs":"","Fields":[{"Name":"Accept","Docs":"","Typewords":["bool"]},{"Name":"Reason","Docs":"","Typewords":["string"]},{"Name":"Details","Docs":"","Typewords":["[]","string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Classified","Docs":"","Typewords":["bool"]},{"Name":"Score","Docs":"","Typewords":["float64"]},{"Name":"Threshold","Docs":"","Typewords":["float64"]},{"Name":"Significant","Docs":"","Typewords":["bool"]},{"Name":"HamWords","Docs":"","Typewords":["[]","MessageJunkWord"]},{"Name":"SpamWords","Docs":"","Typewords":["[]","MessageJunkWord"]}]},
+	"MessageJunkWord": {"Name":"MessageJunkWord","Docs":"","Fields":[{"Name":"Word","Docs":"","Typewords":["string"]},{"Name":"Score","Docs":"","Typewords":["float64"]}]},
+	"ComposeMessage": {"Name":"ComposeMessage","Docs":"","Fields":[{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Cc","Docs":"","Typewords":["[]","string"]},{"Name":"Bcc","Docs":"","Typewords":["[]","string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"TextBody","Docs":"","Typewords":["string"]},{"Name":"ResponseMessageID","Docs":"","Typewords":["int64"]},{"Name":"DraftMessageID","Docs":"","Typewords":["int64"]},{"Name":"DraftID","Docs":"","Typewords":["int64"]}]},
+	"ResponseCompose": {"Name":"ResponseCompose","Docs":"","Fields":[{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Cc","Docs":"","Typewords":["[]","string"]},{"Name":"Bcc","Docs":"","Typewords":["[]","string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"InReplyTo","Docs":"","Typewords":["string"]},{"Name":"References","Docs":"","Typewords":["[]","string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"TextBody","Docs":"","Typewords":["string"]},{"Name":"EditOffset","Docs":"","Typewords":["int32"]},{"Name":"HTMLBody","Docs":"","Typewords":["string"]},{"Name":"ForwardAttachments","Docs":"","Typewords":["ForwardAttachments"]}]},
 	"ForwardAttachments": {"Name":"ForwardAttachments","Docs":"","Fields":[{"Name":"MessageID","Docs":"","Typewords":["int64"]},{"Name":"Paths","Docs":"","Typewords":["[]","[]","int32"]}]},
+	"SubmitMessage": {"Name":"SubmitMessage","Docs":"","Fields":[{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["[]","string"]},{"Name":"Cc","Docs":"","Typewords":["[]","string"]},{"Name":"Bcc","Docs":"","Typewords":["[]","string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"TextBody","Docs":"","Typewords":["string"]},{"Name":"HTMLBody","Docs":"","Typewords":["string"]},{"Name":"InlineFiles","Docs":"","Typewords":["[]","InlineFile"]},{"Name":"Attachments","Docs":"","Typewords":["[]","File"]},{"Name":"ForwardAttachments","Docs":"","Typewords":["ForwardAttachments"]},{"Name":"AttachMessageID","Docs":"","Typewords":["int64"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"ResponseMessageID","Docs":"","Typewords":["int64"]},{"Name":"UserAgent","Docs":"","Typewords":["string"]},{"Name":"RequireTLS","Docs":"","Typewords":["nullable","bool"]},{"Name":"FutureRelease","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"ArchiveThread","Docs":"","Typewords":["bool"]},{"Name":"ArchiveReferenceMailboxID","Docs":"","Typewords":["int64"]},{"Name":"DraftMessageID","Docs":"","Typewords":["int64"]},{"Name":"DraftID","Docs":"","Typewords":["int64"]},{"Name":"PGPSign","Docs":"","Typewords":["bool"]},{"Name":"PGPEncrypt","Docs":"","Typewords":["bool"]},{"Name":"PGPPassphrase","Docs":"","Typewords":["string"]}]},
+	"InlineFile": {"Name":"InlineFile","Docs":"","Fields":[{"Name":"ContentID","Docs":"","Typewords":["string"]},{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"DataURI","Docs":"","Typewords":["string"]}]},
+	"File": {"Name":"File","Docs":"","Fields":[{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"DataURI","Docs":"","Typewords":["string"]}]},
+	"SubmitResult": {"Name":"SubmitResult","Docs":"","Fields":[{"Name":"QueueMsgIDs","Docs":"","Typewords":["[]","int64"]},{"Name":"SentMessageID","Docs":"","Typewords":["int64"]},{"Name":"UndoUntil","Docs":"","Typewords":["nullable","timestamp"]}]},
+	"PGPResult": {"Name":"PGPResult","Docs":"","Fields":[{"Name":"Decrypted","Docs":"","Typewords":["bool"]},{"Name":"Signed","Docs":"","Typewords":["bool"]},{"Name":"SignatureValid","Docs":"","Typewords":["bool"]},{"Name":"SignatureError","Docs":"","Typewords":["string"]},{"Name":"SignerKeyID","Docs":"","Typewords":["string"]},{"Name":"SignerAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"SignerFrom","Docs":"","Typewords":["bool"]},{"Name":"SignerKeySource","Docs":"","Typewords":["string"]},{"Name":"Texts","Docs":"","Typewords":["[]","string"]}]},
+	"Unsubscribe": {"Name":"Unsubscribe","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Time","Docs":"","Typewords":["timestamp"]},{"Name":"FromAddress","Docs":"","Typewords":["string"]},{"Name":"ListID","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["int64"]},{"Name":"Method","Docs":"","Typewords":["string"]},{"Name":"Target","Docs":"","Typewords":["string"]},{"Name":"Error","Docs":"","Typewords":["string"]}]},
+	"BulkAction": {"Name":"BulkAction","Docs":"","Fields":[{"Name":"Op","Docs":"","Typewords":["BulkOp"]},{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"Label","Docs":"","Typewords":["string"]}]},
 	"Mailbox": {"Name":"Mailbox","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"CreateSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"ModSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"Expunged","Docs":"","Typewords":["bool"]},{"Name":"ParentID","Docs":"","Typewords":["int64"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"UIDValidity","Docs":"","Typewords":["uint32"]},{"Name":"UIDNext","Docs":"","Typewords":["UID"]},{"Name":"Archive","Docs":"","Typewords":["bool"]},{"Name":"Draft","Docs":"","Typewords":["bool"]},{"Name":"Junk","Docs":"","Typewords":["bool"]},{"Name":"Sent","Docs":"","Typewords":["bool"]},{"Name":"Trash","Docs":"","Typewords":["bool"]},{"Name":"Keywords","Docs":"","Typewords":["[]","string"]},{"Name":"HaveCounts","Docs":"","Typewords":["bool"]},{"Name":"Total","Docs":"","Typewords":["int64"]},{"Name":"Deleted","Docs":"","Typewords":["int64"]},{"Name":"Unread","Docs":"","Typewords":["int64"]},{"Name":"Unseen","Docs":"","Typewords":["int64"]},{"Name":"Size","Docs":"","Typewords":["int64"]}]},
+	"ThreadSummary": {"Name":"ThreadSummary","Docs":"","Fields":[{"Name":"ThreadID","Docs":"","Typewords":["int64"]},{"Name":"MessageIDs","Docs":"","Typewords":["[]","int64"]},{"Name":"MailboxIDs","Docs":"","Typewords":["[]","int64"]},{"Name":"Unread","Docs":"","Typewords":["int32"]},{"Name":"Participants","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Snippet","Docs":"","Typewords":["string"]},{"Name":"Latest","Docs":"","Typewords":["timestamp"]},{"Name":"Muted","Docs":"","Typewords":["bool"]},{"Name":"Collapsed","Docs":"","Typewords":["bool"]}]},
 	"RecipientSecurity": {"Name":"RecipientSecurity","Docs":"","Fields":[{"Name":"STARTTLS","Docs":"","Typewords":["SecurityResult"]},{"Name":"MTASTS","Docs":"","Typewords":["SecurityResult"]},{"Name":"DNSSEC","Docs":"","Typewords":["SecurityResult"]},{"Name":"DANE","Docs":"","Typewords":["SecurityResult"]},{"Name":"RequireTLS","Docs":"","Typewords":["SecurityResult"]}]},
-	"Settings": {"Name":"Settings","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["uint8"]},{"Name":"Signature","Docs":"","Typewords":["string"]},{"Name":"Quoting","Docs":"","Typewords":["Quoting"]},{"Name":"ShowAddressSecurity","Docs":"","Typewords":["bool"]},{"Name":"ShowHTML","Docs":"","Typewords":["bool"]},{"Name":"NoShowShortcuts","Docs":"","Typewords":["bool"]},{"Name":"ShowHeaders","Docs":"","Typewords":["[]","string"]}]},
+	"Settings": {"Name":"Settings","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["uint8"]},{"Name":"Signature","Docs":"","Typewords":["string"]},{"Name":"Quoting","Docs":"","Typewords":["Quoting"]},{"Name":"ShowAddressSecurity","Docs":"","Typewords":["bool"]},{"Name":"ShowHTML","Docs":"","Typewords":["bool"]},{"Name":"NoShowShortcuts","Docs":"","Typewords":["bool"]},{"Name":"ShowHeaders","Docs":"","Typewords":["[]","string"]},{"Name":"UndoSendSeconds","Docs":"","Typewords":["int32"]},{"Name":"HTMLAllowedTags","Docs":"","Typewords":["[]","string"]},{"Name":"HTMLAllowedAttributes","Docs":"","Typewords":["[]","string"]},{"Name":"RemoteContentProxy","Docs":"","Typewords":["bool"]},{"Name":"Labels","Docs":"","Typewords":["[]","Label"]},{"Name":"NotifyMailboxes","Docs":"","Typewords":["[]","string"]},{"Name":"NotifySenders","Docs":"","Typewords":["[]","string"]}]},
+	"Label": {"Name":"Label","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Color","Docs":"","Typewords":["string"]}]},
+	"Template": {"Name":"Template","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Text","Docs":"","Typewords":["string"]},{"Name":"Attachments","Docs":"","Typewords":["[]","TemplateAttachment"]}]},
+	"TemplateAttachment": {"Name":"TemplateAttachment","Docs":"","Fields":[{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"DataURI","Docs":"","Typewords":["string"]}]},
+	"SharedTemplate": {"Name":"SharedTemplate","Docs":"","Fields":[{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Text","Docs":"","Typewords":["string"]}]},
+	"TemplateRef": {"Name":"TemplateRef","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Name","Docs":"","Typewords":["string"]}]},
+	"ComposeTemplate": {"Name":"ComposeTemplate","Docs":"","Fields":[{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"Text","Docs":"","Typewords":["string"]},{"Name":"Attachments","Docs":"","Typewords":["[]","File"]}]},
 	"Ruleset": {"Name":"Ruleset","Docs":"","Fields":[{"Name":"SMTPMailFromRegexp","Docs":"","Typewords":["string"]},{"Name":"MsgFromRegexp","Docs":"","Typewords":["string"]},{"Name":"VerifiedDomain","Docs":"","Typewords":["string"]},{"Name":"HeadersRegexp","Docs":"","Typewords":["{}","string"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"ListAllowDomain","Docs":"","Typewords":["string"]},{"Name":"AcceptRejectsToMailbox","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"Comment","Docs":"","Typewords":["string"]},{"Name":"VerifiedDNSDomain","Docs":"","Typewords":["Domain"]},{"Name":"ListAllowDNSDomain","Docs":"","Typewords":["Domain"]}]},
-	"EventStart": {"Name":"EventStart","Docs":"","Fields":[{"Name":"SSEID","Docs":"","Typewords":["int64"]},{"Name":"LoginAddress","Docs":"","Typewords":["MessageAddress"]},{"Name":"Addresses","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"DomainAddressConfigs","Docs":"","Typewords":["{}","DomainAddressConfig"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"Mailboxes","Docs":"","Typewords":["[]","Mailbox"]},{"Name":"RejectsMailbox","Docs":"","Typewords":["string"]},{"Name":"Settings","Docs":"","Typewords":["Settings"]},{"Name":"AccountPath","Docs":"","Typewords":["string"]},{"Name":"Version","Docs":"","Typewords":["string"]}]},
+	"Rule": {"Name":"Rule","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Position","Docs":"","Typewords":["int32"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Disabled","Docs":"","Typewords":["bool"]},{"Name":"From","Docs":"","Typewords":["string"]},{"Name":"To","Docs":"","Typewords":["string"]},{"Name":"SubjectContains","Docs":"","Typewords":["string"]},{"Name":"HasAttachment","Docs":"","Typewords":["bool"]},{"Name":"ListID","Docs":"","Typewords":["string"]},{"Name":"Mailbox","Docs":"","Typewords":["string"]},{"Name":"MarkRead","Docs":"","Typewords":["bool"]},{"Name":"ForwardTo","Docs":"","Typewords":["[]","string"]},{"Name":"Delete","Docs":"","Typewords":["bool"]},{"Name":"Stop","Docs":"","Typewords":["bool"]}]},
+	"SavedSearch": {"Name":"SavedSearch","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Search","Docs":"","Typewords":["string"]},{"Name":"Filter","Docs":"","Typewords":["Filter"]},{"Name":"NotFilter","Docs":"","Typewords":["NotFilter"]},{"Name":"Total","Docs":"","Typewords":["int32"]},{"Name":"Unseen","Docs":"","Typewords":["int32"]}]},
+	"Draft": {"Name":"Draft","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Version","Docs":"","Typewords":["int64"]},{"Name":"Updated","Docs":"","Typewords":["timestamp"]},{"Name":"Message","Docs":"","Typewords":["ComposeMessage"]},{"Name":"Attachments","Docs":"","Typewords":["[]","DraftAttachment"]}]},
+	"DraftAttachment": {"Name":"DraftAttachment","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"ContentType","Docs":"","Typewords":["string"]},{"Name":"Size","Docs":"","Typewords":["int64"]}]},
+	"Delegation": {"Name":"Delegation","Docs":"","Fields":[{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailboxes","Docs":"","Typewords":["[]","string"]},{"Name":"Write","Docs":"","Typewords":["bool"]},{"Name":"Send","Docs":"","Typewords":["string"]}]},
+	"DelegatedAccess": {"Name":"DelegatedAccess","Docs":"","Fields":[{"Name":"Account","Docs":"","Typewords":["string"]},{"Name":"Mailboxes","Docs":"","Typewords":["[]","string"]},{"Name":"Write","Docs":"","Typewords":["bool"]},{"Name":"Send","Docs":"","Typewords":["string"]},{"Name":"Addresses","Docs":"","Typewords":["[]","string"]}]},
+	"MessageItem": {"Name":"MessageItem","Docs":"","Fields":[{"Name":"Message","Docs":"","Typewords":["Message"]},{"Name":"Envelope","Docs":"","Typewords":["MessageEnvelope"]},{"Name":"Attachments","Docs":"","Typewords":["[]","Attachment"]},{"Name":"IsSigned","Docs":"","Typewords":["bool"]},{"Name":"IsEncrypted","Docs":"","Typewords":["bool"]},{"Name":"SMIME","Docs":"","Typewords":["nullable","SMIMEStatus"]},{"Name":"Calendar","Docs":"","Typewords":["nullable","CalendarInvite"]},{"Name":"Unsubscribe","Docs":"","Typewords":["nullable","ListUnsubscribe"]},{"Name":"Auth","Docs":"","Typewords":["nullable","AuthResults"]},{"Name":"MatchQuery","Docs":"","Typewords":["bool"]},{"Name":"MoreHeaders","Docs":"","Typewords":["[]","[]","string"]},{"Name":"SenderWarnings","Docs":"","Typewords":["[]","SenderWarning"]}]},
+	"Message": {"Name":"Message","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"UID","Docs":"","Typewords":["UID"]},{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"ModSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"CreateSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"Expunged","Docs":"","Typewords":["bool"]},{"Name":"IsReject","Docs":"","Typewords":["bool"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"MailboxOrigID","Docs":"","Typewords":["int64"]},{"Name":"MailboxDestinedID","Docs":"","Typewords":["int64"]},{"Name":"Received","Docs":"","Typewords":["timestamp"]},{"Name":"SaveDate","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"RemoteIPMasked1","Docs":"","Typewords":["string"]},{"Name":"RemoteIPMasked2","Docs":"","Typewords":["string"]},{"Name":"RemoteIPMasked3","Docs":"","Typewords":["string"]},{"Name":"EHLODomain","Docs":"","Typewords":["string"]},{"Name":"MailFrom","Docs":"","Typewords":["string"]},{"Name":"MailFromLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"MailFromDomain","Docs":"","Typewords":["string"]},{"Name":"RcptToLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RcptToDomain","Docs":"","Typewords":["string"]},{"Name":"MsgFromLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"MsgFromDomain","Docs":"","Typewords":["string"]},{"Name":"MsgFromOrgDomain","Docs":"","Typewords":["string"]},{"Name":"EHLOValidated","Docs":"","Typewords":["bool"]},{"Name":"MailFromValidated","Docs":"","Typewords":["bool"]},{"Name":"MsgFromValidated","Docs":"","Typewords":["bool"]},{"Name":"EHLOValidation","Docs":"","Typewords":["Validation"]},{"Name":"MailFromValidation","Docs":"","Typewords":["Validation"]},{"Name":"MsgFromValidation","Docs":"","Typewords":["Validation"]},{"Name":"DKIMDomains","Docs":"","Typewords":["[]","string"]},{"Name":"OrigEHLODomain","Docs":"","Typewords":["string"]},{"Name":"OrigDKIMDomains","Docs":"","Typewords":["[]","string"]},{"Name":"Auth","Docs":"","Typewords":["nullable","MessageAuth"]},{"Name":"ReputationJunk","Docs":"","Typewords":["nullable","bool"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"SubjectBase","Docs":"","Typewords":["string"]},{"Name":"MessageHash","Docs":"","Typewords":["nullable","string"]},{"Name":"ThreadID","Docs":"","Typewords":["int64"]},{"Name":"ThreadParentIDs","Docs":"","Typewords":["[]","int64"]},{"Name":"ThreadMissingLink","Docs":"","Typewords":["bool"]},{"Name":"ThreadMuted","Docs":"","Typewords":["bool"]},{"Name":"ThreadCollapsed","Docs":"","Typewords":["bool"]},{"Name":"IsMailingList","Docs":"","Typewords":["bool"]},{"Name":"DSN","Docs":"","Typewords":["bool"]},{"Name":"ReceivedTLSVersion","Docs":"","Typewords":["uint16"]},{"Name":"ReceivedTLSCipherSuite","Docs":"","Typewords":["uint16"]},{"Name":"ReceivedRequireTLS","Docs":"","Typewords":["bool"]},{"Name":"Seen","Docs":"","Typewords":["bool"]},{"Name":"Answered","Docs":"","Typewords":["bool"]},{"Name":"Flagged","Docs":"","Typewords":["bool"]},{"Name":"Forwarded","Docs":"","Typewords":["bool"]},{"Name":"Junk","Docs":"","Typewords":["bool"]},{"Name":"Notjunk","Docs":"","Typewords":["bool"]},{"Name":"Deleted","Docs":"","Typewords":["bool"]},{"Name":"Draft","Docs":"","Typewords":["bool"]},{"Name":"Phishing","Docs":"","Typewords":["bool"]},{"Name":"MDNSent","Docs":"","Typewords":["bool"]},{"Name":"Keywords","Docs":"","Typewords":["[]","string"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"TrainedJunk","Docs":"","Typewords":["nullable","bool"]},{"Name":"MsgPrefix","Docs":"","Typewords":["nullable","string"]},{"Name":"StoredSize","Docs":"","Typewords":["int64"]},{"Name":"Compressed","Docs":"","Typewords":["bool"]},{"Name":"Preview","Docs":"","Typewords":["nullable","string"]},{"Name":"ParsedBuf","Docs":"","Typewords":["nullable","string"]}]},
+	"MessageAuth": {"Name":"MessageAuth","Docs":"","Fields":[{"Name":"IPRev","Docs":"","Typewords":["string"]},{"Name":"IPRevAuthentic","Docs":"","Typewords":["bool"]},{"Name":"SPF","Docs":"","Typewords":["string"]},{"Name":"SPFIdentity","Docs":"","Typewords":["string"]},{"Name":"SPFDomain","Docs":"","Typewords":["string"]},{"Name":"SPFAuthentic","Docs":"","Typewords":["bool"]},{"Name":"DKIM","Docs":"","Typewords":["[]","MessageAuthDKIM"]},{"Name":"DMARC","Docs":"","Typewords":["string"]},{"Name":"DMARCDomain","Docs":"","Typewords":["string"]},{"Name":"DMARCPolicy","Docs":"","Typewords":["string"]},{"Name":"DMARCAuthentic","Docs":"","Typewords":["bool"]},{"Name":"DMARCOverrides","Docs":"","Typewords":["[]","string"]},{"Name":"ARCSealer","Docs":"","Typewords":["string"]},{"Name":"BIMI","Docs":"","Typewords":["string"]},{"Name":"BIMIDomain","Docs":"","Typewords":["string"]},{"Name":"DNSBLs","Docs":"","Typewords":["[]","MessageAuthDNSBL"]}]},
+	"MessageAuthDKIM": {"Name":"MessageAuthDKIM","Docs":"","Fields":[{"Name":"Status","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]},{"Name":"Selector","Docs":"","Typewords":["string"]},{"Name":"Algorithm","Docs":"","Typewords":["string"]},{"Name":"Identity","Docs":"","Typewords":["string"]},{"Name":"Authentic","Docs":"","Typewords":["bool"]},{"Name":"Error","Docs":"","Typewords":["string"]}]},
+	"MessageAuthDNSBL": {"Name":"MessageAuthDNSBL","Docs":"","Fields":[{"Name":"Zone","Docs":"","Typewords":["string"]},{"Name":"Status","Docs":"","Typewords":["string"]}]},
+	"MessageEnvelope": {"Name":"MessageEnvelope","Docs":"","Fields":[{"Name":"Date","Docs":"","Typewords":["timestamp"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"Sender","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"ReplyTo","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"To","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"CC","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"BCC","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"InReplyTo","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]}]},
+	"Attachment": {"Name":"Attachment","Docs":"","Fields":[{"Name":"Path","Docs":"","Typewords":["[]","int32"]},{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"Part","Docs":"","Typewords":["Part"]}]},
+	"SMIMEStatus": {"Name":"SMIMEStatus","Docs":"","Fields":[{"Name":"Valid","Docs":"","Typewords":["bool"]},{"Name":"Error","Docs":"","Typewords":["string"]},{"Name":"SignerAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"SignerFrom","Docs":"","Typewords":["bool"]},{"Name":"Fingerprint","Docs":"","Typewords":["string"]}]},
+	"CalendarInvite": {"Name":"CalendarInvite","Docs":"","Fields":[{"Name":"Path","Docs":"","Typewords":["[]","int32"]},{"Name":"Method","Docs":"","Typewords":["string"]},{"Name":"UID","Docs":"","Typewords":["string"]},{"Name":"Sequence","Docs":"","Typewords":["int32"]},{"Name":"Summary","Docs":"","Typewords":["string"]},{"Name":"Location","Docs":"","Typewords":["string"]},{"Name":"Description","Docs":"","Typewords":["string"]},{"Name":"Start","Docs":"","Typewords":["timestamp"]},{"Name":"End","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"AllDay","Docs":"","Typewords":["bool"]},{"Name":"Organizer","Docs":"","Typewords":["CalendarAttendee"]},{"Name":"Attendees","Docs":"","Typewords":["[]","CalendarAttendee"]}]},
+	"CalendarAttendee": {"Name":"CalendarAttendee","Docs":"","Fields":[{"Name":"Name","Docs":"","Typewords":["string"]},{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"Status","Docs":"","Typewords":["string"]},{"Name":"Role","Docs":"","Typewords":["string"]}]},
+	"ListUnsubscribe": {"Name":"ListUnsubscribe","Docs":"","Fields":[{"Name":"URL","Docs":"","Typewords":["string"]},{"Name":"Mailto","Docs":"","Typewords":["string"]},{"Name":"OneClick","Docs":"","Typewords":["bool"]}]},
+	"AuthResults": {"Name":"AuthResults","Docs":"","Fields":[{"Name":"IPRev","Docs":"","Typewords":["AuthCheck"]},{"Name":"SPF","Docs":"","Typewords":["AuthCheck"]},{"Name":"DKIM","Docs":"","Typewords":["[]","AuthCheck"]},{"Name":"DMARC","Docs":"","Typewords":["AuthCheck"]},{"Name":"BIMI","Docs":"","Typewords":["AuthCheck"]},{"Name":"BIMILogoURL","Docs":"","Typewords":["string"]},{"Name":"BIMILogoPath","Docs":"","Typewords":["string"]},{"Name":"Reason","Docs":"","Typewords":["string"]},{"Name":"ReasonDetails","Docs":"","Typewords":["[]","string"]}]},
+	"AuthCheck": {"Name":"AuthCheck","Docs":"","Fields":[{"Name":"Result","Docs":"","Typewords":["string"]},{"Name":"Domain","Docs":"","Typewords":["string"]}]},
+	"SenderWarning": {"Name":"SenderWarning","Docs":"","Fields":[{"Name":"Kind","Docs":"","Typewords":["SenderWarningKind"]},{"Name":"Similar","Docs":"","Typewords":["string"]},{"Name":"Text","Docs":"","Typewords":["string"]}]},
+	"EventStart": {"Name":"EventStart","Docs":"","Fields":[{"Name":"SSEID","Docs":"","Typewords":["int64"]},{"Name":"LoginAddress","Docs":"","Typewords":["MessageAddress"]},{"Name":"Addresses","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"DomainAddressConfigs","Docs":"","Typewords":["{}","DomainAddressConfig"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"Mailboxes","Docs":"","Typewords":["[]","Mailbox"]},{"Name":"RejectsMailbox","Docs":"","Typewords":["string"]},{"Name":"Settings","Docs":"","Typewords":["Settings"]},{"Name":"Identities","Docs":"","Typewords":["[]","Identity"]},{"Name":"PGPAddresses","Docs":"","Typewords":["[]","string"]},{"Name":"Delegated","Docs":"","Typewords":["[]","DelegatedAccess"]},{"Name":"SavedSearches","Docs":"","Typewords":["[]","SavedSearch"]},{"Name":"AccountPath","Docs":"","Typewords":["string"]},{"Name":"QuotaWarning","Docs":"","Typewords":["string"]},{"Name":"Impersonation","Docs":"","Typewords":["nullable","Impersonation"]},{"Name":"Version","Docs":"","Typewords":["string"]},{"Name":"SearchIndexSalt","Docs":"","Typewords":["string"]},{"Name":"Resumed","Docs":"","Typewords":["bool"]}]},
 	"DomainAddressConfig": {"Name":"DomainAddressConfig","Docs":"","Fields":[{"Name":"LocalpartCatchallSeparators","Docs":"","Typewords":["[]","string"]},{"Name":"LocalpartCaseSensitive","Docs":"","Typewords":["bool"]}]},
+	"Identity": {"Name":"Identity","Docs":"","Fields":[{"Name":"Address","Docs":"","Typewords":["string"]},{"Name":"DisplayName","Docs":"","Typewords":["string"]},{"Name":"Signature","Docs":"","Typewords":["[]","string"]},{"Name":"SignatureHTML","Docs":"","Typewords":["string"]},{"Name":"ReplyQuoting","Docs":"","Typewords":["string"]},{"Name":"ReplyTo","Docs":"","Typewords":["string"]},{"Name":"Default","Docs":"","Typewords":["bool"]}]},
+	"Impersonation": {"Name":"Impersonation","Docs":"","Fields":[{"Name":"By","Docs":"","Typewords":["string"]},{"Name":"ReadOnly","Docs":"","Typewords":["bool"]},{"Name":"End","Docs":"","Typewords":["timestamp"]}]},
 	"EventViewErr": {"Name":"EventViewErr","Docs":"","Fields":[{"Name":"ViewID","Docs":"","Typewords":["int64"]},{"Name":"RequestID","Docs":"","Typewords":["int64"]},{"Name":"Err","Docs":"","Typewords":["string"]}]},
 	"EventViewReset": {"Name":"EventViewReset","Docs":"","Fields":[{"Name":"ViewID","Docs":"","Typewords":["int64"]},{"Name":"RequestID","Docs":"","Typewords":["int64"]}]},
 	"EventViewMsgs": {"Name":"EventViewMsgs","Docs":"","Fields":[{"Name":"ViewID","Docs":"","Typewords":["int64"]},{"Name":"RequestID","Docs":"","Typewords":["int64"]},{"Name":"MessageItems","Docs":"","Typewords":["[]","[]","MessageItem"]},{"Name":"ParsedMessage","Docs":"","Typewords":["nullable","ParsedMessage"]},{"Name":"ViewEnd","Docs":"","Typewords":["bool"]}]},
-	"MessageItem": {"Name":"MessageItem","Docs":"","Fields":[{"Name":"Message","Docs":"","Typewords":["Message"]},{"Name":"Envelope","Docs":"","Typewords":["MessageEnvelope"]},{"Name":"Attachments","Docs":"","Typewords":["[]","Attachment"]},{"Name":"IsSigned","Docs":"","Typewords":["bool"]},{"Name":"IsEncrypted","Docs":"","Typewords":["bool"]},{"Name":"MatchQuery","Docs":"","Typewords":["bool"]},{"Name":"MoreHeaders","Docs":"","Typewords":["[]","[]","string"]}]},
-	"Message": {"Name":"Message","Docs":"","Fields":[{"Name":"ID","Docs":"","Typewords":["int64"]},{"Name":"UID","Docs":"","Typewords":["UID"]},{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"ModSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"CreateSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"Expunged","Docs":"","Typewords":["bool"]},{"Name":"IsReject","Docs":"","Typewords":["bool"]},{"Name":"IsForward","Docs":"","Typewords":["bool"]},{"Name":"MailboxOrigID","Docs":"","Typewords":["int64"]},{"Name":"MailboxDestinedID","Docs":"","Typewords":["int64"]},{"Name":"Received","Docs":"","Typewords":["timestamp"]},{"Name":"SaveDate","Docs":"","Typewords":["nullable","timestamp"]},{"Name":"RemoteIP","Docs":"","Typewords":["string"]},{"Name":"RemoteIPMasked1","Docs":"","Typewords":["string"]},{"Name":"RemoteIPMasked2","Docs":"","Typewords":["string"]},{"Name":"RemoteIPMasked3","Docs":"","Typewords":["string"]},{"Name":"EHLODomain","Docs":"","Typewords":["string"]},{"Name":"MailFrom","Docs":"","Typewords":["string"]},{"Name":"MailFromLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"MailFromDomain","Docs":"","Typewords":["string"]},{"Name":"RcptToLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"RcptToDomain","Docs":"","Typewords":["string"]},{"Name":"MsgFromLocalpart","Docs":"","Typewords":["Localpart"]},{"Name":"MsgFromDomain","Docs":"","Typewords":["string"]},{"Name":"MsgFromOrgDomain","Docs":"","Typewords":["string"]},{"Name":"EHLOValidated","Docs":"","Typewords":["bool"]},{"Name":"MailFromValidated","Docs":"","Typewords":["bool"]},{"Name":"MsgFromValidated","Docs":"","Typewords":["bool"]},{"Name":"EHLOValidation","Docs":"","Typewords":["Validation"]},{"Name":"MailFromValidation","Docs":"","Typewords":["Validation"]},{"Name":"MsgFromValidation","Docs":"","Typewords":["Validation"]},{"Name":"DKIMDomains","Docs":"","Typewords":["[]","string"]},{"Name":"OrigEHLODomain","Docs":"","Typewords":["string"]},{"Name":"OrigDKIMDomains","Docs":"","Typewords":["[]","string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]},{"Name":"SubjectBase","Docs":"","Typewords":["string"]},{"Name":"MessageHash","Docs":"","Typewords":["nullable","string"]},{"Name":"ThreadID","Docs":"","Typewords":["int64"]},{"Name":"ThreadParentIDs","Docs":"","Typewords":["[]","int64"]},{"Name":"ThreadMissingLink","Docs":"","Typewords":["bool"]},{"Name":"ThreadMuted","Docs":"","Typewords":["bool"]},{"Name":"ThreadCollapsed","Docs":"","Typewords":["bool"]},{"Name":"IsMailingList","Docs":"","Typewords":["bool"]},{"Name":"DSN","Docs":"","Typewords":["bool"]},{"Name":"ReceivedTLSVersion","Docs":"","Typewords":["uint16"]},{"Name":"ReceivedTLSCipherSuite","Docs":"","Typewords":["uint16"]},{"Name":"ReceivedRequireTLS","Docs":"","Typewords":["bool"]},{"Name":"Seen","Docs":"","Typewords":["bool"]},{"Name":"Answered","Docs":"","Typewords":["bool"]},{"Name":"Flagged","Docs":"","Typewords":["bool"]},{"Name":"Forwarded","Docs":"","Typewords":["bool"]},{"Name":"Junk","Docs":"","Typewords":["bool"]},{"Name":"Notjunk","Docs":"","Typewords":["bool"]},{"Name":"Deleted","Docs":"","Typewords":["bool"]},{"Name":"Draft","Docs":"","Typewords":["bool"]},{"Name":"Phishing","Docs":"","Typewords":["bool"]},{"Name":"MDNSent","Docs":"","Typewords":["bool"]},{"Name":"Keywords","Docs":"","Typewords":["[]","string"]},{"Name":"Size","Docs":"","Typewords":["int64"]},{"Name":"TrainedJunk","Docs":"","Typewords":["nullable","bool"]},{"Name":"MsgPrefix","Docs":"","Typewords":["nullable","string"]},{"Name":"Preview","Docs":"","Typewords":["nullable","string"]},{"Name":"ParsedBuf","Docs":"","Typewords":["nullable","string"]}]},
-	"MessageEnvelope": {"Name":"MessageEnvelope","Docs":"","Fields":[{"Name":"Date","Docs":"","Typewords":["timestamp"]},{"Name":"Subject","Docs":"","Typewords":["string"]},{"Name":"From","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"Sender","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"ReplyTo","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"To","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"CC","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"BCC","Docs":"","Typewords":["[]","MessageAddress"]},{"Name":"InReplyTo","Docs":"","Typewords":["string"]},{"Name":"MessageID","Docs":"","Typewords":["string"]}]},
-	"Attachment": {"Name":"Attachment","Docs":"","Fields":[{"Name":"Path","Docs":"","Typewords":["[]","int32"]},{"Name":"Filename","Docs":"","Typewords":["string"]},{"Name":"Part","Docs":"","Typewords":["Part"]}]},
 	"EventViewChanges": {"Name":"EventViewChanges","Docs":"","Fields":[{"Name":"ViewID","Docs":"","Typewords":["int64"]},{"Name":"Changes","Docs":"","Typewords":["[]","[]","any"]}]},
 	"ChangeMsgAdd": {"Name":"ChangeMsgAdd","Docs":"","Fields":[{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"UID","Docs":"","Typewords":["UID"]},{"Name":"ModSeq","Docs":"","Typewords":["ModSeq"]},{"Name":"Flags","Docs":"","Typewords":["Flags"]},{"Name":"Keywords","Docs":"","Typewords":["[]","string"]},{"Name":"MessageCountIMAP","Docs":"","Typewords":["uint32"]},{"Name":"Unseen","Docs":"","Typewords":["uint32"]},{"Name":"MessageItems","Docs":"","Typewords":["[]","MessageItem"]}]},
 	"Flags": {"Name":"Flags","Docs":"","Fields":[{"Name":"Seen","Docs":"","Typewords":["bool"]},{"Name":"Answered","Docs":"","Typewords":["bool"]},{"Name":"Flagged","Docs":"","Typewords":["bool"]},{"Name":"Forwarded","Docs":"","Typewords":["bool"]},{"Name":"Junk","Docs":"","Typewords":["bool"]},{"Name":"Notjunk","Docs":"","Typewords":["bool"]},{"Name":"Deleted","Docs":"","Typewords":["bool"]},{"Name":"Draft","Docs":"","Typewords":["bool"]},{"Name":"Phishing","Docs":"","Typewords":["bool"]},{"Name":"MDNSent","Docs":"","Typewords":["bool"]}]},
@@ -653,6 +1167,9 @@ export const types: TypenameMap = {
 	"ChangeMailboxSpecialUse": {"Name":"ChangeMailboxSpecialUse","Docs":"","Fields":[{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"SpecialUse","Docs":"","Typewords":["SpecialUse"]},{"Name":"ModSeq","Docs":"","Typewords":["ModSeq"]}]},
 	"SpecialUse": {"Name":"SpecialUse","Docs":"","Fields":[{"Name":"Archive","Docs":"","Typewords":["bool"]},{"Name":"Draft","Docs":"","Typewords":["bool"]},{"Name":"Junk","Docs":"","Typewords":["bool"]},{"Name":"Sent","Docs":"","Typewords":["bool"]},{"Name":"Trash","Docs":"","Typewords":["bool"]}]},
 	"ChangeMailboxKeywords": {"Name":"ChangeMailboxKeywords","Docs":"","Fields":[{"Name":"MailboxID","Docs":"","Typewords":["int64"]},{"Name":"MailboxName","Docs":"","Typewords":["string"]},{"Name":"Keywords","Docs":"","Typewords":["[]","string"]}]},
+	"EventSavedSearches": {"Name":"EventSavedSearches","Docs":"","Fields":[{"Name":"SavedSearches","Docs":"","Typewords":["[]","SavedSearch"]}]},
+	"EventBulkProgress": {"Name":"EventBulkProgress","Docs":"","Fields":[{"Name":"Op","Docs":"","Typewords":["BulkOp"]},{"Name":"Done","Docs":"","Typewords":["int32"]},{"Name":"Total","Docs":"","Typewords":["int32"]}]},
+	"ViewResume": {"Name":"ViewResume","Docs":"","Fields":[{"Name":"LastEventID","Docs":"","Typewords":["int64"]},{"Name":"LastMessageReceived","Docs":"","Typewords":["timestamp"]},{"Name":"ViewEnd","Docs":"","Typewords":["bool"]}]},
 	"ModSeq": {"Name":"ModSeq","Docs":"","Values":null},
 	"UID": {"Name":"UID","Docs":"","Values":null},
 	"Validation": {"Name":"Validation","Docs":"","Values":[{"Name":"ValidationUnknown","Value":0,"Docs":""},{"Name":"ValidationStrict","Value":1,"Docs":""},{"Name":"ValidationDMARC","Value":2,"Docs":""},{"Name":"ValidationRelaxed","Value":3,"Docs":""},{"Name":"ValidationPass","Value":4,"Docs":""},{"Name":"ValidationNeutral","Value":5,"Docs":""},{"Name":"ValidationTemperror","Value":6,"Docs":""},{"Name":"ValidationPermerror","Value":7,"Docs":""},{"Name":"ValidationFail","Value":8,"Docs":""},{"Name":"ValidationSoftfail","Value":9,"Docs":""},{"Name":"ValidationNone","Value":10,"Docs":""}]},
@@ -660,12 +1177,17 @@ export const types: TypenameMap = {
 	"ThreadMode": {"Name":"ThreadMode","Docs":"","Values":[{"Name":"ThreadOff","Value":"off","Docs":""},{"Name":"ThreadOn","Value":"on","Docs":""},{"Name":"ThreadUnread","Value":"unread","Docs":""}]},
 	"AttachmentType": {"Name":"AttachmentType","Docs":"","Values":[{"Name":"AttachmentIndifferent","Value":"","Docs":""},{"Name":"AttachmentNone","Value":"none","Docs":""},{"Name":"AttachmentAny","Value":"any","Docs":""},{"Name":"AttachmentImage","Value":"image","Docs":""},{"Name":"AttachmentPDF","Value":"pdf","Docs":""},{"Name":"AttachmentArchive","Value":"archive","Docs":""},{"Name":"AttachmentSpreadsheet","Value":"spreadsheet","Docs":""},{"Name":"AttachmentDocument","Value":"document","Docs":""},{"Name":"AttachmentPresentation","Value":"presentation","Docs":""}]},
 	"ViewMode": {"Name":"ViewMode","Docs":"","Values":[{"Name":"ModeText","Value":"text","Docs":""},{"Name":"ModeHTML","Value":"html","Docs":""},{"Name":"ModeHTMLExt","Value":"htmlext","Docs":""}]},
+	"ResponseMode": {"Name":"ResponseMode","Docs":"","Values":[{"Name":"ResponseReply","Value":"reply","Docs":""},{"Name":"ResponseReplyAll","Value":"replyall","Docs":""},{"Name":"ResponseReplyList","Value":"replylist","Docs":""},{"Name":"ResponseForward","Value":"forward","Docs":""}]},
+	"BulkOp": {"Name":"BulkOp","Docs":"","Values":[{"Name":"BulkMarkRead","Value":"markread","Docs":""},{"Name":"BulkMarkUnread","Value":"markunread","Docs":""},{"Name":"BulkMove","Value":"move","Docs":""},{"Name":"BulkLabel","Value":"label","Docs":""},{"Name":"BulkDelete","Value":"delete","Docs":""}]},
 	"SecurityResult": {"Name":"SecurityResult","Docs":"","Values":[{"Name":"SecurityResultError","Value":"error","Docs":""},{"Name":"SecurityResultNo","Value":"no","Docs":""},{"Name":"SecurityResultYes","Value":"yes","Docs":""},{"Name":"SecurityResultUnknown","Value":"unknown","Docs":""}]},
 	"Quoting": {"Name":"Quoting","Docs":"","Values":[{"Name":"Default","Value":"","Docs":""},{"Name":"Bottom","Value":"bottom","Docs":""},{"Name":"Top","Value":"top","Docs":""}]},
 	"Localpart": {"Name":"Localpart","Docs":"","Values":null},
+	"SenderWarningKind": {"Name":"SenderWarningKind","Docs":"","Values":[{"Name":"SenderWarningDisplayName","Value":"displayname","Docs":""},{"Name":"SenderWarningLookalike","Value":"lookalike","Docs":""},{"Name":"SenderWarningReplyTo","Value":"replyto","Docs":""}]},
 }
 
 export const parser = {
+	PasskeyGetOptions: (v: any) => parse("PasskeyGetOptions", v) as PasskeyGetOptions,
+	PasskeyAssertion: (v: any) => parse("PasskeyAssertion", v) as PasskeyAssertion,
 	Request: (v: any) => parse("Request", v) as Request,
 	Query: (v: any) => parse("Query", v) as Query,
 	Filter: (v: any) => parse("Filter", v) as Filter,
@@ -677,24 +1199,58 @@ export const parser = {
 	Address: (v: any) => parse("Address", v) as Address,
 	MessageAddress: (v: any) => parse("MessageAddress", v) as MessageAddress,
 	Domain: (v: any) => parse("Domain", v) as Domain,
+	SMIMECertificate: (v: any) => parse("SMIMECertificate", v) as SMIMECertificate,
 	FromAddressSettings: (v: any) => parse("FromAddressSettings", v) as FromAddressSettings,
+	MessageJunkVerdict: (v: any) => parse("MessageJunkVerdict", v) as MessageJunkVerdict,
+	MessageJunkWord: (v: any) => parse("MessageJunkWord", v) as MessageJunkWord,
 	ComposeMessage: (v: any) => parse("ComposeMessage", v) as ComposeMessage,
+	ResponseCompose: (v: any) => parse("ResponseCompose", v) as ResponseCompose,
+	ForwardAttachments: (v: any) => parse("ForwardAttachments", v) as ForwardAttachments,
 	SubmitMessage: (v: any) => parse("SubmitMessage", v) as SubmitMessage,
+	InlineFile: (v: any) => parse("InlineFile", v) as InlineFile,
 	File: (v: any) => parse("File", v) as File,
-	ForwardAttachments: (v: any) => parse("ForwardAttachments", v) as ForwardAttachments,
+	SubmitResult: (v: any) => parse("SubmitResult", v) as SubmitResult,
+	PGPResult: (v: any) => parse("PGPResult", v) as PGPResult,
+	Unsubscribe: (v: any) => parse("Unsubscribe", v) as Unsubscribe,
+	BulkAction: (v: any) => parse("BulkAction", v) as BulkAction,
 	Mailbox: (v: any) => parse("Mailbox", v) as Mailbox,
+	ThreadSummary: (v: any) => parse("ThreadSummary", v) as ThreadSummary,
 	RecipientSecurity: (v: any) => parse("RecipientSecurity", v) as RecipientSecurity,
 	Settings: (v: any) => parse("Settings", v) as Settings,
+	Label: (v: any) => parse("Label", v) as Label,
+	Template: (v: any) => parse("Template", v) as Template,
+	TemplateAttachment: (v: any) => parse("TemplateAttachment", v) as TemplateAttachment,
+	SharedTemplate: (v: any) => parse("SharedTemplate", v) as SharedTemplate,
+	TemplateRef: (v: any) => parse("TemplateRef", v) as TemplateRef,
+	ComposeTemplate: (v: any) => parse("ComposeTemplate", v) as ComposeTemplate,
 	Ruleset: (v: any) => parse("Ruleset", v) as Ruleset,
+	Rule: (v: any) => parse("Rule", v) as Rule,
+	SavedSearch: (v: any) => parse("SavedSearch", v) as SavedSearch,
+	Draft: (v: any) => parse("Draft", v) as Draft,
+	DraftAttachment: (v: any) => parse("DraftAttachment", v) as DraftAttachment,
+	Delegation: (v: any) => parse("Delegation", v) as Delegation,
+	DelegatedAccess: (v: any) => parse("DelegatedAccess", v) as DelegatedAccess,
+	MessageItem: (v: any) => parse("MessageItem", v) as MessageItem,
+	Message: (v: any) => parse("Message", v) as Message,
+	MessageAuth: (v: any) => parse("MessageAuth", v) as MessageAuth,
+	MessageAuthDKIM: (v: any) => parse("MessageAuthDKIM", v) as MessageAuthDKIM,
+	MessageAuthDNSBL: (v: any) => parse("MessageAuthDNSBL", v) as MessageAuthDNSBL,
+	MessageEnvelope: (v: any) => parse("MessageEnvelope", v) as MessageEnvelope,
+	Attachment: (v: any) => parse("Attachment", v) as Attachment,
+	SMIMEStatus: (v: any) => parse("SMIMEStatus", v) as SMIMEStatus,
+	CalendarInvite: (v: any) => parse("CalendarInvite", v) as CalendarInvite,
+	CalendarAttendee: (v: any) => parse("CalendarAttendee", v) as CalendarAttendee,
+	ListUnsubscribe: (v: any) => parse("ListUnsubscribe", v) as ListUnsubscribe,
+	AuthResults: (v: any) => parse("AuthResults", v) as AuthResults,
+	AuthCheck: (v: any) => parse("AuthCheck", v) as AuthCheck,
+	SenderWarning: (v: any) => parse("SenderWarning", v) as SenderWarning,
 	EventStart: (v: any) => parse("EventStart", v) as EventStart,
 	DomainAddressConfig: (v: any) => parse("DomainAddressConfig", v) as DomainAddressConfig,
+	Identity: (v: any) => parse("Identity", v) as Identity,
+	Impersonation: (v: any) => parse("Impersonation", v) as Impersonation,
 	EventViewErr: (v: any) => parse("EventViewErr", v) as EventViewErr,
 	EventViewReset: (v: any) => parse("EventViewReset", v) as EventViewReset,
 	EventViewMsgs: (v: any) => parse("EventViewMsgs", v) as EventViewMsgs,
-	MessageItem: (v: any) => parse("MessageItem", v) as MessageItem,
-	Message: (v: any) => parse("Message", v) as Message,
-	MessageEnvelope: (v: any) => parse("MessageEnvelope", v) as MessageEnvelope,
-	Attachment: (v: any) => parse("Attachment", v) as Attachment,
 	EventViewChanges: (v: any) => parse("EventViewChanges", v) as EventViewChanges,
 	ChangeMsgAdd: (v: any) => parse("ChangeMsgAdd", v) as ChangeMsgAdd,
 	Flags: (v: any) => parse("Flags", v) as Flags,
@@ -708,6 +1264,9 @@ export const parser = {
 	ChangeMailboxSpecialUse: (v: any) => parse("ChangeMailboxSpecialUse", v) as ChangeMailboxSpecialUse,
 	SpecialUse: (v: any) => parse("SpecialUse", v) as SpecialUse,
 	ChangeMailboxKeywords: (v: any) => parse("ChangeMailboxKeywords", v) as ChangeMailboxKeywords,
+	EventSavedSearches: (v: any) => parse("EventSavedSearches", v) as EventSavedSearches,
+	EventBulkProgress: (v: any) => parse("EventBulkProgress", v) as EventBulkProgress,
+	ViewResume: (v: any) => parse("ViewResume", v) as ViewResume,
 	ModSeq: (v: any) => parse("ModSeq", v) as ModSeq,
 	UID: (v: any) => parse("UID", v) as UID,
 	Validation: (v: any) => parse("Validation", v) as Validation,
@@ -715,9 +1274,12 @@ export const parser = {
 	ThreadMode: (v: any) => parse("ThreadMode", v) as ThreadMode,
 	AttachmentType: (v: any) => parse("AttachmentType", v) as AttachmentType,
 	ViewMode: (v: any) => parse("ViewMode", v) as ViewMode,
+	ResponseMode: (v: any) => parse("ResponseMode", v) as ResponseMode,
+	BulkOp: (v: any) => parse("BulkOp", v) as BulkOp,
 	SecurityResult: (v: any) => parse("SecurityResult", v) as SecurityResult,
 	Quoting: (v: any) => parse("Quoting", v) as Quoting,
 	Localpart: (v: any) => parse("Localpart", v) as Localpart,
+	SenderWarningKind: (v: any) => parse("SenderWarningKind", v) as SenderWarningKind,
 }
 
 let defaultOptions: ClientOptions = {slicesNullable: true, mapsNullable: true, nullableOptional: true}
@@ -758,15 +1320,50 @@ export class Client {
 	}
 
 	// Login returns a session token for the credentials, or fails with error code
-	// "user:badLogin". Call LoginPrep to get a loginToken.
-	async Login(loginToken: string, username: string, password: string): Promise<CSRFToken> {
+	// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+	// authentication is enabled, totp must be a code from the authenticator app,
+	// otherwise the login fails with error code "user:totpRequired".
+	async Login(loginToken: string, username: string, password: string, totp: string): Promise<CSRFToken> {
 		const fn: string = "Login"
-		const paramTypes: string[][] = [["string"],["string"],["string"]]
+		const paramTypes: string[][] = [["string"],["string"],["string"],["string"]]
 		const returnTypes: string[][] = [["CSRFToken"]]
-		const params: any[] = [loginToken, username, password]
+		const params: any[] = [loginToken, username, password, totp]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CSRFToken
 	}
 
+	// PasskeyLoginStart returns the parameters for the browser to login with a
+	// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+	// of the browser.
+	async PasskeyLoginStart(loginToken: string): Promise<PasskeyGetOptions> {
+		const fn: string = "PasskeyLoginStart"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["PasskeyGetOptions"]]
+		const params: any[] = [loginToken]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PasskeyGetOptions
+	}
+
+	// PasskeyLogin returns a session token for a passkey login started with
+	// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+	// password, the passkey must verify the user, e.g. with a PIN. With username and
+	// password, the passkey is the second factor.
+	async PasskeyLogin(loginToken: string, username: string, password: string, assertion: PasskeyAssertion): Promise<CSRFToken> {
+		const fn: string = "PasskeyLogin"
+		const paramTypes: string[][] = [["string"],["string"],["string"],["PasskeyAssertion"]]
+		const returnTypes: string[][] = [["CSRFToken"]]
+		const params: any[] = [loginToken, username, password, assertion]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as CSRFToken
+	}
+
+	// OIDCEnabled returns whether logging in through an OpenID Connect identity
+	// provider is possible, by sending the browser to "oidc/login".
+	async OIDCEnabled(): Promise<boolean> {
+		const fn: string = "OIDCEnabled"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["bool"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as boolean
+	}
+
 	// Logout invalidates the session token.
 	async Logout(): Promise<void> {
 		const fn: string = "Logout"
@@ -829,6 +1426,18 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// SMIMEPin pins the key of the certificate that signed the message for the From
+	// address of the message. Messages from the address signed with another key are
+	// shown with a warning. Only messages with a valid signature by a certificate for
+	// the From address can be pinned.
+	async SMIMEPin(msgID: number): Promise<void> {
+		const fn: string = "SMIMEPin"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [msgID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// MessageFindMessageID looks up a message by Message-Id header, and returns the ID
 	// of the message in storage. Used when opening a previously saved draft message
 	// for editing again.
@@ -841,6 +1450,18 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
 	}
 
+	// MessageJunkVerdict returns the outcome of the junk analysis done when the
+	// message was delivered over SMTP, with the signals and junk filter words that
+	// contributed, for explaining why a message was (not) marked as junk. Returns
+	// nil for messages without stored verdict, e.g. sent or imported messages.
+	async MessageJunkVerdict(msgID: number): Promise<MessageJunkVerdict | null> {
+		const fn: string = "MessageJunkVerdict"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = [["nullable","MessageJunkVerdict"]]
+		const params: any[] = [msgID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MessageJunkVerdict | null
+	}
+
 	// MessageCompose composes a message and saves it to the mailbox. Used for
 	// saving draft messages.
 	async MessageCompose(m: ComposeMessage, mailboxID: number): Promise<number> {
@@ -851,6 +1472,19 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
 	}
 
+	// MessageResponse returns a prepared reply or forward for a message. If selection
+	// is not empty, it is quoted instead of the text of the message, e.g. text the
+	// user selected. The response is sent with MessageSubmit, with ResponseMessageID
+	// set, and for forwards optionally with the attachments in ForwardAttachments, or
+	// the entire message with AttachMessageID.
+	async MessageResponse(messageID: number, mode: ResponseMode, selection: string): Promise<ResponseCompose> {
+		const fn: string = "MessageResponse"
+		const paramTypes: string[][] = [["int64"],["ResponseMode"],["string"]]
+		const returnTypes: string[][] = [["ResponseCompose"]]
+		const params: any[] = [messageID, mode, selection]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ResponseCompose
+	}
+
 	// MessageSubmit sends a message by submitting it the outgoing email queue. The
 	// message is sent to all addresses listed in the To, Cc and Bcc addresses, without
 	// Bcc message header.
@@ -858,14 +1492,90 @@ export class Client {
 	// If a Sent mailbox is configured, messages are added to it after submitting
 	// to the delivery queue. If Bcc addresses were present, a header is prepended
 	// to the message stored in the Sent mailbox.
-	async MessageSubmit(m: SubmitMessage): Promise<void> {
+	// 
+	// If UndoSendSeconds is set in the settings and no FutureRelease is requested,
+	// delivery is held back for that many seconds, during which MessageSubmitUndo
+	// can cancel it.
+	async MessageSubmit(m: SubmitMessage): Promise<SubmitResult> {
 		const fn: string = "MessageSubmit"
 		const paramTypes: string[][] = [["SubmitMessage"]]
-		const returnTypes: string[][] = []
+		const returnTypes: string[][] = [["SubmitResult"]]
 		const params: any[] = [m]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SubmitResult
+	}
+
+	// MessageSubmitUndo cancels delivery of a message submitted with MessageSubmit
+	// that is still held back, see SubmitResult. The copy of the message in the Sent
+	// mailbox, if any, is moved to the Drafts mailbox and marked as draft, so it can
+	// be edited and sent again.
+	async MessageSubmitUndo(queueMsgIDs: number[] | null, sentMessageID: number): Promise<void> {
+		const fn: string = "MessageSubmitUndo"
+		const paramTypes: string[][] = [["[]","int64"],["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [queueMsgIDs, sentMessageID]
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// MessagePGP verifies the signature of an OpenPGP signed message, or decrypts an
+	// OpenPGP encrypted message and verifies the signature inside it, if any. The
+	// private keys of the account are decrypted with passphrase, it can be empty for
+	// keys without passphrase. Signatures are verified with the keys stored in the
+	// account, and with keys from the Web Key Directory of the From address.
+	async MessagePGP(msgID: number, passphrase: string): Promise<PGPResult> {
+		const fn: string = "MessagePGP"
+		const paramTypes: string[][] = [["int64"],["string"]]
+		const returnTypes: string[][] = [["PGPResult"]]
+		const params: any[] = [msgID, passphrase]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as PGPResult
+	}
+
+	// CalendarReply responds to the meeting invitation in a message, with status
+	// ACCEPTED, TENTATIVE or DECLINED. The reply is sent to the organizer, from the
+	// first attendee address of the account.
+	async CalendarReply(msgID: number, status: string): Promise<SubmitResult> {
+		const fn: string = "CalendarReply"
+		const paramTypes: string[][] = [["int64"],["string"]]
+		const returnTypes: string[][] = [["SubmitResult"]]
+		const params: any[] = [msgID, status]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SubmitResult
+	}
+
+	// MessageUnsubscribe unsubscribes from the mailing list of a message with a
+	// List-Unsubscribe header. Method "oneclick" makes an HTTPS POST request to the
+	// URL from the header, RFC 8058. Method "mailto" sends an unsubscribe message to
+	// the address from the header, from the address of the account the message was
+	// sent to. The request is added to the unsubscribe history of the sender, also
+	// when a one-click request fails.
+	async MessageUnsubscribe(msgID: number, method: string): Promise<Unsubscribe> {
+		const fn: string = "MessageUnsubscribe"
+		const paramTypes: string[][] = [["int64"],["string"]]
+		const returnTypes: string[][] = [["Unsubscribe"]]
+		const params: any[] = [msgID, method]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Unsubscribe
+	}
+
+	// UnsubscribeHistory returns the unsubscribe requests made for messages from an
+	// address, most recent first.
+	async UnsubscribeHistory(fromAddress: string): Promise<Unsubscribe[] | null> {
+		const fn: string = "UnsubscribeHistory"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["[]","Unsubscribe"]]
+		const params: any[] = [fromAddress]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Unsubscribe[] | null
+	}
+
+	// SenderBlock adds the message From address of a message, or its domain if
+	// domain is set, to the sender lists of the account with action "reject",
+	// causing future messages from the sender to be rejected during SMTP. The
+	// pattern of the added entry is returned.
+	async SenderBlock(msgID: number, domain: boolean): Promise<string> {
+		const fn: string = "SenderBlock"
+		const paramTypes: string[][] = [["int64"],["bool"]]
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = [msgID, domain]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
 	// MessageMove moves messages to another mailbox. If the message is already in
 	// the mailbox an error is returned.
 	async MessageMove(messageIDs: number[] | null, mailboxID: number): Promise<void> {
@@ -914,6 +1624,20 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// MessageBulk applies an action to all messages matching the filters of the
+	// query, e.g. the current view of the client, without the client having to
+	// fetch and send all message IDs. Messages are changed in batches, each in its
+	// own transaction. If sseID is nonzero, EventBulkProgress events are sent on the
+	// SSE connection after each batch. Deleting is permanent. The number of messages
+	// that were changed is returned.
+	async MessageBulk(sseID: number, query: Query, action: BulkAction): Promise<number> {
+		const fn: string = "MessageBulk"
+		const paramTypes: string[][] = [["int64"],["Query"],["BulkAction"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [sseID, query, action]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
 	// MailboxCreate creates a new mailbox.
 	async MailboxCreate(name: string): Promise<void> {
 		const fn: string = "MailboxCreate"
@@ -953,8 +1677,9 @@ export class Client {
 	}
 
 	// CompleteRecipient returns autocomplete matches for a recipient, returning the
-	// matches, most recently used first, and whether this is the full list and further
-	// requests for longer prefixes aren't necessary.
+	// matches, most recently used first followed by matching contacts from the
+	// address books, and whether this is the full list and further requests for
+	// longer prefixes aren't necessary.
 	async CompleteRecipient(search: string): Promise<[string[] | null, boolean]> {
 		const fn: string = "CompleteRecipient"
 		const paramTypes: string[][] = [["string"]]
@@ -993,6 +1718,38 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// Threads returns conversations that have at least one message in the mailbox, or
+	// in any mailbox if mailboxID is 0. Threads are ordered by their most recent
+	// message, newest first. The first offset threads are skipped, at most limit
+	// threads are returned.
+	async Threads(mailboxID: number, offset: number, limit: number): Promise<ThreadSummary[] | null> {
+		const fn: string = "Threads"
+		const paramTypes: string[][] = [["int64"],["int32"],["int32"]]
+		const returnTypes: string[][] = [["[]","ThreadSummary"]]
+		const params: any[] = [mailboxID, offset, limit]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ThreadSummary[] | null
+	}
+
+	// ThreadsCollapse sets the collapsed state of all messages in the threads, across
+	// all mailboxes.
+	async ThreadsCollapse(threadIDs: number[] | null, collapse: boolean): Promise<void> {
+		const fn: string = "ThreadsCollapse"
+		const paramTypes: string[][] = [["[]","int64"],["bool"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [threadIDs, collapse]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// ThreadsMute sets the muted state of all messages in the threads, across all
+	// mailboxes. If threads are muted, they are also marked collapsed.
+	async ThreadsMute(threadIDs: number[] | null, mute: boolean): Promise<void> {
+		const fn: string = "ThreadsMute"
+		const paramTypes: string[][] = [["[]","int64"],["bool"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [threadIDs, mute]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// RecipientSecurity looks up security properties of the address in the
 	// single-address message addressee (as it appears in a To/Cc/Bcc/etc header).
 	async RecipientSecurity(messageAddressee: string): Promise<RecipientSecurity> {
@@ -1021,6 +1778,47 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// Templates returns the message templates of the account, and the templates
+	// shared by domains the account has an address in.
+	async Templates(): Promise<[Template[] | null, SharedTemplate[] | null]> {
+		const fn: string = "Templates"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Template"],["[]","SharedTemplate"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [Template[] | null, SharedTemplate[] | null]
+	}
+
+	// TemplateSave adds a template to the account if its ID is zero, and otherwise
+	// updates the existing template. The saved template is returned.
+	async TemplateSave(t: Template): Promise<Template> {
+		const fn: string = "TemplateSave"
+		const paramTypes: string[][] = [["Template"]]
+		const returnTypes: string[][] = [["Template"]]
+		const params: any[] = [t]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Template
+	}
+
+	// TemplateRemove removes a template from the account.
+	async TemplateRemove(templateID: number): Promise<void> {
+		const fn: string = "TemplateRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [templateID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// TemplateApply returns the subject, text and attachments of a template for
+	// composing a message from fromAddress to recipients "to", with placeholders
+	// replaced. Placeholders for the recipient are replaced with the name and address
+	// of the first recipient.
+	async TemplateApply(ref: TemplateRef, fromAddress: string, to: string[] | null): Promise<ComposeTemplate> {
+		const fn: string = "TemplateApply"
+		const paramTypes: string[][] = [["TemplateRef"],["string"],["[]","string"]]
+		const returnTypes: string[][] = [["ComposeTemplate"]]
+		const params: any[] = [ref, fromAddress, to]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ComposeTemplate
+	}
+
 	async RulesetSuggestMove(msgID: number, mbSrcID: number, mbDstID: number): Promise<[string, string, boolean, string, Ruleset | null]> {
 		const fn: string = "RulesetSuggestMove"
 		const paramTypes: string[][] = [["int64"],["int64"],["int64"]]
@@ -1061,13 +1859,295 @@ export class Client {
 		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
 	}
 
+	// Rules returns the message rules of the account, in order of evaluation.
+	async Rules(): Promise<Rule[] | null> {
+		const fn: string = "Rules"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Rule"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Rule[] | null
+	}
+
+	// RuleSave adds a rule to the account if its ID is zero, and otherwise updates
+	// the existing rule. The saved rule is returned.
+	async RuleSave(r: Rule): Promise<Rule> {
+		const fn: string = "RuleSave"
+		const paramTypes: string[][] = [["Rule"]]
+		const returnTypes: string[][] = [["Rule"]]
+		const params: any[] = [r]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Rule
+	}
+
+	// RuleRemove removes a rule from the account.
+	async RuleRemove(ruleID: number): Promise<void> {
+		const fn: string = "RuleRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [ruleID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// RuleRun applies the enabled rules to the existing messages in a mailbox, moving
+	// messages and marking them read. Messages are not forwarded. The number of
+	// messages that matched a rule is returned.
+	async RuleRun(mailboxID: number): Promise<number> {
+		const fn: string = "RuleRun"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [mailboxID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// SavedSearches returns the saved searches of the account, with message counts.
+	async SavedSearches(): Promise<SavedSearch[] | null> {
+		const fn: string = "SavedSearches"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","SavedSearch"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SavedSearch[] | null
+	}
+
+	// SavedSearchSave adds a saved search to the account if its ID is zero, and
+	// otherwise updates the existing saved search. The saved search is returned with
+	// message counts.
+	async SavedSearchSave(ss: SavedSearch): Promise<SavedSearch> {
+		const fn: string = "SavedSearchSave"
+		const paramTypes: string[][] = [["SavedSearch"]]
+		const returnTypes: string[][] = [["SavedSearch"]]
+		const params: any[] = [ss]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as SavedSearch
+	}
+
+	// SavedSearchRemove removes a saved search from the account.
+	async SavedSearchRemove(savedSearchID: number): Promise<void> {
+		const fn: string = "SavedSearchRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [savedSearchID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// Drafts returns the autosaved drafts of messages being composed, most recently
+	// saved first, e.g. for continuing after the browser was closed. Drafts that
+	// haven't been saved for 30 days are removed.
+	async Drafts(): Promise<Draft[] | null> {
+		const fn: string = "Drafts"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Draft"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Draft[] | null
+	}
+
+	// DraftSave autosaves the compose state of a draft. A new draft is created if ID
+	// is 0. For existing drafts, Version must be the version of the last save, which
+	// is incremented, otherwise the draft was changed from another device or browser
+	// window and error code "user:draftConflict" is returned.
+	async DraftSave(d: Draft): Promise<Draft> {
+		const fn: string = "DraftSave"
+		const paramTypes: string[][] = [["Draft"]]
+		const returnTypes: string[][] = [["Draft"]]
+		const params: any[] = [d]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Draft
+	}
+
+	// DraftRemove removes a draft and its attachments.
+	async DraftRemove(draftID: number): Promise<void> {
+		const fn: string = "DraftRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [draftID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DraftAttachmentAdd uploads an attachment for a draft, so it doesn't have to be
+	// uploaded again with each save or when sending.
+	async DraftAttachmentAdd(draftID: number, f: File): Promise<DraftAttachment> {
+		const fn: string = "DraftAttachmentAdd"
+		const paramTypes: string[][] = [["int64"],["File"]]
+		const returnTypes: string[][] = [["DraftAttachment"]]
+		const params: any[] = [draftID, f]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as DraftAttachment
+	}
+
+	// DraftAttachmentRemove removes an uploaded attachment from a draft.
+	async DraftAttachmentRemove(attachmentID: number): Promise<void> {
+		const fn: string = "DraftAttachmentRemove"
+		const paramTypes: string[][] = [["int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [attachmentID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// LabelSave adds a label to the end of the list of labels, or updates the color
+	// of an existing label. Messages are not changed.
+	async LabelSave(label: Label): Promise<Label> {
+		const fn: string = "LabelSave"
+		const paramTypes: string[][] = [["Label"]]
+		const returnTypes: string[][] = [["Label"]]
+		const params: any[] = [label]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as Label
+	}
+
+	// LabelRename renames a label, and the labels below it in the hierarchy. The
+	// keywords of affected messages are changed in the same transaction. If a label
+	// with the new name already exists, messages with the old label get the existing
+	// label. The number of changed messages is returned.
+	async LabelRename(oldName: string, newName: string): Promise<number> {
+		const fn: string = "LabelRename"
+		const paramTypes: string[][] = [["string"],["string"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [oldName, newName]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// LabelRemove removes a label, and the labels below it in the hierarchy. The
+	// keywords are removed from messages in the same transaction. The number of
+	// changed messages is returned.
+	async LabelRemove(name: string): Promise<number> {
+		const fn: string = "LabelRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = [["int32"]]
+		const params: any[] = [name]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as number
+	}
+
+	// LabelsOrder sets the display order of labels. Names must be those of all
+	// existing labels.
+	async LabelsOrder(names: string[] | null): Promise<void> {
+		const fn: string = "LabelsOrder"
+		const paramTypes: string[][] = [["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [names]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// WebPushKey returns the public key of the account for Web Push, base64url
+	// encoded, for use as "applicationServerKey" when subscribing in the browser.
+	async WebPushKey(): Promise<string> {
+		const fn: string = "WebPushKey"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["string"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as string
+	}
+
+	// WebPushSubscribe adds a Web Push subscription of the browser, or updates the
+	// keys of an existing subscription with the same endpoint. Notifications about
+	// new messages matching the settings are sent to the subscription. The keys are
+	// base64url encoded, as returned by the browser.
+	async WebPushSubscribe(endpoint: string, p256dh: string, auth: string): Promise<void> {
+		const fn: string = "WebPushSubscribe"
+		const paramTypes: string[][] = [["string"],["string"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [endpoint, p256dh, auth]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// WebPushUnsubscribe removes the Web Push subscription with the endpoint.
+	async WebPushUnsubscribe(endpoint: string): Promise<void> {
+		const fn: string = "WebPushUnsubscribe"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [endpoint]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// Delegations returns the access to mailboxes the account has given to other
+	// accounts, and the access other accounts have given to the account.
+	async Delegations(): Promise<[Delegation[] | null, DelegatedAccess[] | null]> {
+		const fn: string = "Delegations"
+		const paramTypes: string[][] = []
+		const returnTypes: string[][] = [["[]","Delegation"],["[]","DelegatedAccess"]]
+		const params: any[] = []
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [Delegation[] | null, DelegatedAccess[] | null]
+	}
+
+	// DelegationSave gives another account access to mailboxes, replacing access
+	// given earlier to that account.
+	async DelegationSave(d: Delegation): Promise<void> {
+		const fn: string = "DelegationSave"
+		const paramTypes: string[][] = [["Delegation"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [d]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DelegationRemove removes access given to another account.
+	async DelegationRemove(account: string): Promise<void> {
+		const fn: string = "DelegationRemove"
+		const paramTypes: string[][] = [["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [account]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DelegatedMessages returns messages from a mailbox of another account that gave
+	// access, most recently received first.
+	async DelegatedMessages(owner: string, mailbox: string, offset: number, limit: number): Promise<MessageItem[] | null> {
+		const fn: string = "DelegatedMessages"
+		const paramTypes: string[][] = [["string"],["string"],["int32"],["int32"]]
+		const returnTypes: string[][] = [["[]","MessageItem"]]
+		const params: any[] = [owner, mailbox, offset, limit]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as MessageItem[] | null
+	}
+
+	// DelegatedParsedMessage returns a message from a mailbox of another account that
+	// gave access.
+	async DelegatedParsedMessage(owner: string, msgID: number): Promise<ParsedMessage> {
+		const fn: string = "DelegatedParsedMessage"
+		const paramTypes: string[][] = [["string"],["int64"]]
+		const returnTypes: string[][] = [["ParsedMessage"]]
+		const params: any[] = [owner, msgID]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as ParsedMessage
+	}
+
+	// DelegatedFlagsAdd adds flags to messages in mailboxes of another account that
+	// gave write access.
+	async DelegatedFlagsAdd(owner: string, messageIDs: number[] | null, flaglist: string[] | null): Promise<void> {
+		const fn: string = "DelegatedFlagsAdd"
+		const paramTypes: string[][] = [["string"],["[]","int64"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [owner, messageIDs, flaglist]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DelegatedFlagsClear clears flags of messages in mailboxes of another account
+	// that gave write access.
+	async DelegatedFlagsClear(owner: string, messageIDs: number[] | null, flaglist: string[] | null): Promise<void> {
+		const fn: string = "DelegatedFlagsClear"
+		const paramTypes: string[][] = [["string"],["[]","int64"],["[]","string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [owner, messageIDs, flaglist]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DelegatedMessageMove moves messages to another mailbox of the delegation by
+	// another account that gave write access.
+	async DelegatedMessageMove(owner: string, messageIDs: number[] | null, mailbox: string): Promise<void> {
+		const fn: string = "DelegatedMessageMove"
+		const paramTypes: string[][] = [["string"],["[]","int64"],["string"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [owner, messageIDs, mailbox]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
+	// DelegatedMessageDelete permanently deletes messages in mailboxes of another
+	// account that gave write access.
+	async DelegatedMessageDelete(owner: string, messageIDs: number[] | null): Promise<void> {
+		const fn: string = "DelegatedMessageDelete"
+		const paramTypes: string[][] = [["string"],["[]","int64"]]
+		const returnTypes: string[][] = []
+		const params: any[] = [owner, messageIDs]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as void
+	}
+
 	// SSETypes exists to ensure the generated API contains the types, for use in SSE events.
-	async SSETypes(): Promise<[EventStart, EventViewErr, EventViewReset, EventViewMsgs, EventViewChanges, ChangeMsgAdd, ChangeMsgRemove, ChangeMsgFlags, ChangeMsgThread, ChangeMailboxRemove, ChangeMailboxAdd, ChangeMailboxRename, ChangeMailboxCounts, ChangeMailboxSpecialUse, ChangeMailboxKeywords, Flags]> {
+	async SSETypes(): Promise<[EventStart, EventViewErr, EventViewReset, EventViewMsgs, EventViewChanges, ChangeMsgAdd, ChangeMsgRemove, ChangeMsgFlags, ChangeMsgThread, ChangeMailboxRemove, ChangeMailboxAdd, ChangeMailboxRename, ChangeMailboxCounts, ChangeMailboxSpecialUse, ChangeMailboxKeywords, EventSavedSearches, EventBulkProgress, ViewResume, Flags]> {
 		const fn: string = "SSETypes"
 		const paramTypes: string[][] = []
-		const returnTypes: string[][] = [["EventStart"],["EventViewErr"],["EventViewReset"],["EventViewMsgs"],["EventViewChanges"],["ChangeMsgAdd"],["ChangeMsgRemove"],["ChangeMsgFlags"],["ChangeMsgThread"],["ChangeMailboxRemove"],["ChangeMailboxAdd"],["ChangeMailboxRename"],["ChangeMailboxCounts"],["ChangeMailboxSpecialUse"],["ChangeMailboxKeywords"],["Flags"]]
+		const returnTypes: string[][] = [["EventStart"],["EventViewErr"],["EventViewReset"],["EventViewMsgs"],["EventViewChanges"],["ChangeMsgAdd"],["ChangeMsgRemove"],["ChangeMsgFlags"],["ChangeMsgThread"],["ChangeMailboxRemove"],["ChangeMailboxAdd"],["ChangeMailboxRename"],["ChangeMailboxCounts"],["ChangeMailboxSpecialUse"],["ChangeMailboxKeywords"],["EventSavedSearches"],["EventBulkProgress"],["ViewResume"],["Flags"]]
 		const params: any[] = []
-		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [EventStart, EventViewErr, EventViewReset, EventViewMsgs, EventViewChanges, ChangeMsgAdd, ChangeMsgRemove, ChangeMsgFlags, ChangeMsgThread, ChangeMailboxRemove, ChangeMailboxAdd, ChangeMailboxRename, ChangeMailboxCounts, ChangeMailboxSpecialUse, ChangeMailboxKeywords, Flags]
+		return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params) as [EventStart, EventViewErr, EventViewReset, EventViewMsgs, EventViewChanges, ChangeMsgAdd, ChangeMsgRemove, ChangeMsgFlags, ChangeMsgThread, ChangeMailboxRemove, ChangeMailboxAdd, ChangeMailboxRename, ChangeMailboxCounts, ChangeMailboxSpecialUse, ChangeMailboxKeywords, EventSavedSearches, EventBulkProgress, ViewResume, Flags]
 	}
 }
 
@@ -1,25 +1,40 @@
 package webmail
 
 import (
+	"bytes"
 	"context"
+	"crypto/ecdh"
+	cryptorand "crypto/rand"
+	"encoding/base64"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"runtime/debug"
 	"slices"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mjl-/adns"
 	"github.com/mjl-/bstore"
 	"github.com/mjl-/sherpa"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
 
+	"github.com/mjl-/mox/admin"
+	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
 	"github.com/mjl-/mox/mtastsdb"
 	"github.com/mjl-/mox/queue"
 	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/webpush"
+	"github.com/mjl-/mox/wkd"
 )
 
 func tneedErrorCode(t *testing.T, code string, fn func()) {
@@ -105,7 +120,7 @@ func TestAPI(t *testing.T) {
 	loginctx := context.WithValue(ctxbg, requestInfoCtxKey, loginReqInfo)
 
 	// Missing login token.
-	tneedErrorCode(t, "user:error", func() { api.Login(loginctx, "", "mjl@mox.example", pw0) })
+	tneedErrorCode(t, "user:error", func() { api.Login(loginctx, "", "mjl@mox.example", pw0, "") })
 
 	// Login with loginToken.
 	loginCookie := &http.Cookie{Name: "webmaillogin"}
@@ -130,7 +145,7 @@ func TestAPI(t *testing.T) {
 			}
 		}()
 
-		api.Login(loginctx, loginCookie.Value, username, password)
+		api.Login(loginctx, loginCookie.Value, username, password, "")
 	}
 	testLogin("mjl@mox.example", pw0)
 	testLogin("mjl@mox.example", pw1)
@@ -161,8 +176,8 @@ func TestAPI(t *testing.T) {
 	loginCookie2 := &http.Cookie{Name: "webmaillogin"}
 	loginCookie2.Value = api.LoginPrep(loginctx2)
 	loginReqInfo2.Request.Header = http.Header{"Cookie": []string{loginCookie2.String()}}
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "test1234") })
-	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "bogus") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "test1234", "") })
+	tneedErrorCode(t, "user:loginFailed", func() { api.Login(loginctx2, loginCookie2.Value, "disabled@mox.example", "bogus", "") })
 
 	// Context with different IP, for clear rate limit history.
 	reqInfo := requestInfo{log, "mjl@mox.example", acc, "", nil, &http.Request{RemoteAddr: "127.0.0.1:1234"}}
@@ -202,6 +217,19 @@ func TestAPI(t *testing.T) {
 	tneedError(t, func() { api.FlagsClear(ctx, []int64{inboxText.ID}, []string{``}) })
 	tneedError(t, func() { api.FlagsClear(ctx, []int64{inboxText.ID}, []string{`\unknownsystem`}) })
 
+	// SenderBlock
+	tcompare(t, api.SenderBlock(ctx, inboxText.ID, false), "mjl@mox.example")
+	tcompare(t, api.SenderBlock(ctx, inboxText.ID, true), "mox.example")
+	tneedError(t, func() { api.SenderBlock(ctx, 0, false) }) // Bad message.
+	senderList, err := bstore.QueryDB[store.SenderListEntry](ctx, acc.DB).List()
+	tcheck(t, err, "list sender list")
+	tcompare(t, len(senderList), 2)
+	for _, e := range senderList {
+		tcompare(t, e.Action, store.SenderListReject)
+		err := acc.SenderListRemove(log, e.ID)
+		tcheck(t, err, "remove sender list entry")
+	}
+
 	// MailboxSetSpecialUse
 	var inbox, archive, sent, drafts, testbox1 store.Mailbox
 	err = acc.DB.Read(ctx, func(tx *bstore.Tx) error {
@@ -430,6 +458,705 @@ func TestAPI(t *testing.T) {
 		TextBody: fmt.Sprintf("%80s", "tést"),
 	})
 
+	// Prepared responses.
+	rc := api.MessageResponse(ctx, testbox1Alt.ID, ResponseReply, "")
+	tcompare(t, rc.To, []string{"mox <mox@other.example>"}) // Our own message, to the original recipients.
+	tcompare(t, rc.Subject, "Re: test")
+	tcompare(t, rc.InReplyTo, "<alt@localhost>")
+	tcompare(t, rc.References, []string{"<previous@host.example>", "<alt@localhost>"})
+	tcompare(t, strings.HasSuffix(rc.TextBody, ", mjl wrote:\n> the body"), true)
+	tcompare(t, strings.HasPrefix(rc.HTMLBody, "<p>On "), true)
+	tcompare(t, strings.HasSuffix(rc.HTMLBody, ", mjl wrote:</p>\n<blockquote type=\"cite\">the body <img src=\"cid:img1@mox.example\"/>\n</blockquote>\n"), true) // From the HTML part.
+	rc = api.MessageResponse(ctx, testbox1Alt.ID, ResponseReplyAll, "some ☺ text")
+	tcompare(t, strings.HasPrefix(rc.TextBody, "> some ☺ text\n\n"), true) // Selection is bottom-quoted.
+	tcompare(t, rc.EditOffset, 15)                                         // In UTF-16 code units, not bytes.
+	rc = api.MessageResponse(ctx, inboxAttachments.ID, ResponseForward, "")
+	tcompare(t, len(rc.To), 0)
+	tcompare(t, rc.Subject, "Fwd: test")
+	tcompare(t, rc.InReplyTo, "")
+	tcompare(t, strings.HasPrefix(rc.TextBody, "\n\n---- Forwarded Message ----\nSubject:  test\n"), true)
+	tcompare(t, rc.ForwardAttachments, ForwardAttachments{MessageID: inboxAttachments.ID, Paths: [][]int{{1}, {2}, {3}, {4}}})
+	tneedError(t, func() { api.MessageResponse(ctx, inboxHTML.ID, ResponseReplyList, "") }) // No List-Post.
+	tneedError(t, func() { api.MessageResponse(ctx, inboxHTML.ID, ResponseMode("bogus"), "") })
+	tneedError(t, func() { api.MessageResponse(ctx, 0, ResponseReply, "") })
+
+	// Forward with the original message attached.
+	fwdResult := api.MessageSubmit(ctx, SubmitMessage{
+		From:              "mjl@mox.example",
+		To:                []string{"mjl+to@mox.example"},
+		Subject:           rc.Subject,
+		TextBody:          rc.TextBody,
+		AttachMessageID:   testbox1Alt.ID,
+		IsForward:         true,
+		ResponseMessageID: testbox1Alt.ID,
+	})
+	sentm := store.Message{ID: fwdResult.SentMessageID}
+	err = acc.DB.Get(ctx, &sentm)
+	tcheck(t, err, "get sent message")
+	sentbuf, err := io.ReadAll(acc.MessageReader(sentm))
+	tcheck(t, err, "read sent message")
+	tcompare(t, bytes.Contains(sentbuf, []byte("Content-Transfer-Encoding: 7bit\r\nContent-Type: message/rfc822\r\n\r\nFrom: mjl <mjl@mox.example>\r\n")), true)
+	tneedError(t, func() {
+		api.MessageSubmit(ctx, SubmitMessage{From: "mjl@mox.example", To: []string{"mjl+to@mox.example"}, AttachMessageID: testbox1Alt.ID + 999})
+	})
+
+	// Autosaved drafts.
+	d := api.DraftSave(ctx, Draft{Message: ComposeMessage{From: "mjl@mox.example", Subject: "draft", TextBody: "draft text"}})
+	tcompare(t, d.Version, int64(1))
+	d.Message.TextBody = "draft text, edited"
+	d = api.DraftSave(ctx, d)
+	tcompare(t, d.Version, int64(2))
+	tneedErrorCode(t, "user:draftConflict", func() { api.DraftSave(ctx, Draft{ID: d.ID, Version: 1, Message: d.Message}) }) // Changed elsewhere.
+	tneedError(t, func() { api.DraftSave(ctx, Draft{ID: d.ID + 999, Version: 1}) })                                         // Unknown draft.
+	da := api.DraftAttachmentAdd(ctx, d.ID, File{Filename: "test1.png", DataURI: "data:image/png;base64,iVBORw0KGgoAAAANSUhEUg=="})
+	tcompare(t, da.ContentType, `image/png; name=test1.png`)
+	tneedError(t, func() {
+		api.DraftAttachmentAdd(ctx, d.ID+999, File{DataURI: "data:image/png;base64,iVBORw0KGgoAAAANSUhEUg=="})
+	})
+	tneedError(t, func() { api.DraftAttachmentAdd(ctx, d.ID, File{DataURI: "bogus"}) })
+	da2 := api.DraftAttachmentAdd(ctx, d.ID, File{DataURI: "data:text/plain;base64,dGVzdA=="})
+	api.DraftAttachmentRemove(ctx, da2.ID)
+	tneedError(t, func() { api.DraftAttachmentRemove(ctx, da2.ID) })
+	dl := api.Drafts(ctx)
+	tcompare(t, len(dl), 1)
+	tcompare(t, dl[0].Message.TextBody, "draft text, edited")
+	tcompare(t, dl[0].Attachments, []DraftAttachment{da})
+
+	// Save to the Drafts mailbox, with the uploaded attachment.
+	d.Message.DraftID = d.ID
+	composedID := api.MessageCompose(ctx, d.Message, drafts.ID)
+	api.MessageDelete(ctx, []int64{composedID})
+
+	// Send, the draft is removed.
+	api.MessageSubmit(ctx, SubmitMessage{
+		From:     "mjl@mox.example",
+		To:       []string{"mjl+to@mox.example"},
+		Subject:  "draft",
+		TextBody: "draft text, edited",
+		DraftID:  d.ID,
+	})
+	tcompare(t, len(api.Drafts(ctx)), 0)
+	tneedError(t, func() { api.DraftRemove(ctx, d.ID) })
+
+	// Abandoned drafts are removed.
+	d = api.DraftSave(ctx, Draft{Message: ComposeMessage{From: "mjl@mox.example"}})
+	api.DraftAttachmentAdd(ctx, d.ID, File{DataURI: "data:text/plain;base64,dGVzdA=="})
+	err = acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+		sd := store.Draft{ID: d.ID}
+		if err := tx.Get(&sd); err != nil {
+			return err
+		}
+		sd.Updated = time.Now().Add(-draftMaxAge - time.Minute)
+		return tx.Update(&sd)
+	})
+	tcheck(t, err, "aging draft")
+	tcompare(t, len(api.Drafts(ctx)), 0)
+	na, err := bstore.QueryDB[store.DraftAttachment](ctx, acc.DB).Count()
+	tcheck(t, err, "count draft attachments")
+	tcompare(t, na, 0)
+
+	// Templates.
+	tmpl := api.TemplateSave(ctx, store.Template{
+		Name:        "thanks",
+		Subject:     "Thanks {{recipient.firstname}}",
+		Text:        "Hi {{recipient.firstname}},\n\nThanks, {{sender.email}}",
+		Attachments: []store.TemplateAttachment{{Filename: "a.txt", DataURI: "data:text/plain;base64,aGk="}},
+	})
+	tcompare(t, tmpl.ID != 0, true)
+	tneedError(t, func() { api.TemplateSave(ctx, store.Template{Name: "thanks"}) }) // Duplicate name.
+	tneedError(t, func() { api.TemplateSave(ctx, store.Template{Name: " "}) })      // Missing name.
+	tneedError(t, func() {
+		api.TemplateSave(ctx, store.Template{Name: "x", Attachments: []store.TemplateAttachment{{DataURI: "bogus"}}})
+	}) // Bad data URI.
+	tneedError(t, func() { api.TemplateSave(ctx, store.Template{ID: tmpl.ID + 999, Name: "x"}) }) // Does not exist.
+	ct := api.TemplateApply(ctx, TemplateRef{ID: tmpl.ID}, "mjl@mox.example", []string{"Jane Doe <jane@example.org>", "other@example.org"})
+	tcompare(t, ct, ComposeTemplate{"Thanks Jane", "Hi Jane,\n\nThanks, mjl@mox.example", []File{{"a.txt", "data:text/plain;base64,aGk="}}})
+	ct = api.TemplateApply(ctx, TemplateRef{ID: tmpl.ID}, "", nil)
+	tcompare(t, ct.Text, "Hi ,\n\nThanks, ")
+	tneedError(t, func() { api.TemplateApply(ctx, TemplateRef{Domain: "mox.example", Name: "absent"}, "", nil) })
+	tmpl.Subject = ""
+	api.TemplateSave(ctx, tmpl)
+	tmpls, shared := api.Templates(ctx)
+	tcompare(t, len(tmpls), 1)
+	tcompare(t, tmpls[0].Subject, "")
+	tcompare(t, shared, []SharedTemplate{{"mox.example", "welcome", "", "Welcome {{recipient.name}}!"}})
+	ct = api.TemplateApply(ctx, TemplateRef{Domain: "mox.example", Name: "welcome"}, "", []string{"Jane Doe <jane@example.org>"})
+	tcompare(t, ct, ComposeTemplate{"", "Welcome Jane Doe!", []File{}})
+	api.TemplateRemove(ctx, tmpl.ID)
+	tneedError(t, func() { api.TemplateRemove(ctx, tmpl.ID) })
+
+	// Undo send.
+	settings := store.Settings{ID: 1}
+	err = acc.DB.Get(ctx, &settings)
+	tcheck(t, err, "get settings")
+	settings.UndoSendSeconds = 3
+	tneedError(t, func() { api.SettingsSave(ctx, settings) }) // Too short.
+	settings.UndoSendSeconds = 10
+	api.SettingsSave(ctx, settings)
+	result := api.MessageSubmit(ctx, SubmitMessage{
+		From:     "mjl@mox.example",
+		To:       []string{"mjl+to@mox.example", "mjl+to2@mox.example"},
+		TextBody: "oops",
+	})
+	tcompare(t, len(result.QueueMsgIDs), 2)
+	tcompare(t, result.UndoUntil != nil, true)
+	tcompare(t, result.SentMessageID != 0, true)
+	n, err := queue.CountFilter(ctx, queue.Filter{IDs: result.QueueMsgIDs, NextAttempt: ">5s"})
+	tcheck(t, err, "count queue messages")
+	tcompare(t, n, 2)
+	api.MailboxSetSpecialUse(ctx, store.Mailbox{ID: drafts.ID, SpecialUse: store.SpecialUse{Draft: true}}) // Undone message is moved to drafts.
+	api.MessageSubmitUndo(ctx, result.QueueMsgIDs, result.SentMessageID)
+	n, err = queue.CountFilter(ctx, queue.Filter{IDs: result.QueueMsgIDs})
+	tcheck(t, err, "count queue messages")
+	tcompare(t, n, 0)
+	undone := store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &undone)
+	tcheck(t, err, "get undone message")
+	tcompare(t, undone.MailboxID, drafts.ID)
+	tcompare(t, undone.Draft, true)
+	tneedError(t, func() { api.MessageSubmitUndo(ctx, result.QueueMsgIDs, 0) }) // Already canceled.
+	settings.UndoSendSeconds = 0
+	api.SettingsSave(ctx, settings)
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:     "mjl@mox.example",
+		To:       []string{"mjl+to@mox.example"},
+		TextBody: "test",
+	})
+	tcompare(t, result.UndoUntil == nil, true)
+	tcompare(t, len(result.QueueMsgIDs), 0)
+
+	// Sending as identity with HTML signature adds an HTML alternative.
+	tcompare(t, identityHTML("hi <you>\n\nSupport team\n", mox.Conf.Dynamic.Accounts["mjl"].Identities[0]), "<!doctype html>\n<html>\n<head><meta charset=\"utf-8\"></head>\n<body style=\"white-space: pre-wrap\">hi &lt;you&gt;\n\n<div><b>Support team</b></div>\n\n</body>\n</html>\n")
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:        "Support <mjl@mox.example>",
+		To:          []string{"mjl+to@mox.example"},
+		TextBody:    "hi\n\nSupport team",
+		Attachments: []File{{Filename: "a.txt", DataURI: "data:text/plain;base64,aGk="}},
+	})
+	sentMsg := store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent message")
+	sentPart, err := sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent message part")
+	tcompare(t, sentPart.MediaSubType, "MIXED")
+	tcompare(t, len(sentPart.Parts), 2)
+	tcompare(t, sentPart.Parts[0].MediaSubType, "ALTERNATIVE")
+	tcompare(t, len(sentPart.Parts[0].Parts), 2)
+	htmlBuf, err := io.ReadAll(sentPart.Parts[0].Parts[1].Reader())
+	tcheck(t, err, "read html part")
+	tcompare(t, strings.Contains(string(htmlBuf), "<div><b>Support team</b></div>"), true)
+	// Without matching display name, the identity is not used.
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:     "mjl@mox.example",
+		To:       []string{"mjl+to@mox.example"},
+		TextBody: "hi\n\nSupport team",
+	})
+	sentMsg = store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent message")
+	sentPart, err = sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent message part")
+	tcompare(t, sentPart.MediaType, "TEXT")
+
+	// HTML body is sanitized, inline images are added in a multipart/related part.
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:        "mjl@mox.example",
+		To:          []string{"mjl+to@mox.example"},
+		TextBody:    "hi",
+		HTMLBody:    `<p onclick="x()">hi<script>alert(1)</script><img src="cid:img1@mox.example"><a href="javascript:x()">link</a></p>`,
+		InlineFiles: []InlineFile{{ContentID: "img1@mox.example", Filename: "a.png", DataURI: "data:image/png;base64,aGk="}},
+		Attachments: []File{{Filename: "a.txt", DataURI: "data:text/plain;base64,aGk="}},
+	})
+	sentMsg = store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent message")
+	sentPart, err = sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent message part")
+	tcompare(t, sentPart.MediaSubType, "MIXED")
+	tcompare(t, sentPart.Parts[0].MediaSubType, "ALTERNATIVE")
+	related := sentPart.Parts[0].Parts[1]
+	tcompare(t, related.MediaSubType, "RELATED")
+	tcompare(t, len(related.Parts), 2)
+	tcompare(t, *related.Parts[1].ContentID, "<img1@mox.example>")
+	htmlBuf, err = io.ReadAll(related.Parts[0].Reader())
+	tcheck(t, err, "read html part")
+	tcompare(t, strings.TrimSpace(string(htmlBuf)), `<html><head></head><body><p>hi<img src="cid:img1@mox.example"/><a>link</a></p></body></html>`)
+	tneedError(t, func() {
+		api.MessageSubmit(ctx, SubmitMessage{
+			From:        "mjl@mox.example",
+			To:          []string{"mjl+to@mox.example"},
+			TextBody:    "hi",
+			InlineFiles: []InlineFile{{ContentID: "img1@mox.example", Filename: "a.png", DataURI: "data:image/png;base64,aGk="}},
+		})
+	}) // Inline files without html body.
+	tneedError(t, func() {
+		api.MessageSubmit(ctx, SubmitMessage{
+			From:        "mjl@mox.example",
+			To:          []string{"mjl+to@mox.example"},
+			TextBody:    "hi",
+			HTMLBody:    "<p>hi</p>",
+			InlineFiles: []InlineFile{{ContentID: "img1@mox.example", Filename: "a.txt", DataURI: "data:text/plain;base64,aGk="}},
+		})
+	}) // Inline file not an image.
+
+	// Allowed elements and attributes are configurable, but not for scripts.
+	settings.HTMLAllowedTags = []string{"p", "script"}
+	tneedError(t, func() { api.SettingsSave(ctx, settings) })
+	settings.HTMLAllowedTags = []string{"p"}
+	settings.HTMLAllowedAttributes = []string{"onclick"}
+	tneedError(t, func() { api.SettingsSave(ctx, settings) })
+	settings.HTMLAllowedAttributes = []string{"style"}
+	api.SettingsSave(ctx, settings)
+	s, err := sanitizeComposedHTML(newHTMLPolicy(settings), `<p style="color: red" title="x"><b>hi</b><style>p {}</style></p>`)
+	tcheck(t, err, "sanitize html")
+	tcompare(t, s, `<html><head></head><body><p style="color: red">hi</p></body></html>`)
+	settings.HTMLAllowedTags = nil
+	settings.HTMLAllowedAttributes = nil
+	api.SettingsSave(ctx, settings)
+
+	// OpenPGP. Keys are looked up in the account, and in the Web Key Directory, which
+	// we make fail.
+	origTransport := wkd.HTTPClient.Transport
+	wkd.HTTPClient.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return nil, &adns.DNSError{IsNotFound: true}
+		},
+	}
+	defer func() {
+		wkd.HTTPClient.Transport = origTransport
+	}()
+	tneedError(t, func() {
+		api.MessageSubmit(ctx, SubmitMessage{From: "mjl@mox.example", To: []string{"mjl+to@mox.example"}, TextBody: "hi", PGPSign: true})
+	}) // No private key.
+	pgpKey := func(addr string, private bool) store.PGPKey {
+		t.Helper()
+		e, err := openpgp.NewEntity("", "", addr, nil)
+		tcheck(t, err, "new pgp key")
+		// Algorithm preferences, as set by common tools. Keys without preferences are
+		// assumed to only support hashes we cannot encrypt with.
+		for _, id := range e.Identities {
+			id.SelfSignature.PreferredHash = []uint8{8}         // SHA256
+			id.SelfSignature.PreferredSymmetric = []uint8{9, 7} // AES256, AES128
+			err := id.SelfSignature.SignUserId(id.UserId.Id, e.PrimaryKey, e.PrivateKey, nil)
+			tcheck(t, err, "sign user id")
+		}
+		var b bytes.Buffer
+		if private {
+			w, err := armor.Encode(&b, openpgp.PrivateKeyType, nil)
+			tcheck(t, err, "armor")
+			err = e.SerializePrivate(w, nil)
+			tcheck(t, err, "serialize private key")
+			err = w.Close()
+			tcheck(t, err, "close armor")
+		} else {
+			w, err := armor.Encode(&b, openpgp.PublicKeyType, nil)
+			tcheck(t, err, "armor")
+			err = e.Serialize(w)
+			tcheck(t, err, "serialize key")
+			err = w.Close()
+			tcheck(t, err, "close armor")
+		}
+		k, err := store.ParsePGPKey(b.String())
+		tcheck(t, err, "parse pgp key")
+		err = acc.DB.Insert(ctx, &k)
+		tcheck(t, err, "insert pgp key")
+		return k
+	}
+	pgpKey("mjl@mox.example", true)
+
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:     "mjl@mox.example",
+		To:       []string{"mjl+to@mox.example"},
+		TextBody: "signed ☺",
+		PGPSign:  true,
+	})
+	sentMsg = store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent message")
+	sentPart, err = sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent message part")
+	tcompare(t, sentPart.MediaSubType, "SIGNED")
+	tcompare(t, len(sentPart.Parts), 2)
+	tcompare(t, sentPart.Parts[0].ContentTransferEncoding != nil && *sentPart.Parts[0].ContentTransferEncoding == "QUOTED-PRINTABLE", true)
+	pgpResult := api.MessagePGP(ctx, sentMsg.ID, "")
+	tcompare(t, pgpResult.Signed, true)
+	tcompare(t, pgpResult.SignatureValid, true)
+	tcompare(t, pgpResult.SignerFrom, true)
+	tcompare(t, pgpResult.SignerKeySource, "account")
+	tcompare(t, pgpResult.Decrypted, false)
+
+	// Encrypting needs keys for all recipients.
+	tneedError(t, func() {
+		api.MessageSubmit(ctx, SubmitMessage{From: "mjl@mox.example", To: []string{"mjl+to@mox.example"}, TextBody: "hi", PGPEncrypt: true})
+	})
+	pgpKey("mjl+to@mox.example", false)
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:       "mjl@mox.example",
+		To:         []string{"mjl+to@mox.example"},
+		TextBody:   "secret",
+		PGPSign:    true,
+		PGPEncrypt: true,
+	})
+	sentMsg = store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent message")
+	sentPart, err = sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent message part")
+	tcompare(t, sentPart.MediaSubType, "ENCRYPTED")
+	tcompare(t, len(sentPart.Parts), 2)
+	pgpResult = api.MessagePGP(ctx, sentMsg.ID, "")
+	tcompare(t, pgpResult.Decrypted, true)
+	tcompare(t, pgpResult.SignatureValid, true)
+	tcompare(t, pgpResult.Texts, []string{"secret\r\n"})
+	tneedError(t, func() { api.MessagePGP(ctx, inboxMinimal.ID, "") }) // Not an openpgp message.
+
+	// S/MIME signature is verified when parsing. This signature is invalid, and
+	// cannot be pinned.
+	inboxSMIME := &testmsg{"Inbox", store.Flags{}, nil, Message{
+		From:    "mjl <mjl@mox.example>",
+		To:      "mox <mox@other.example>",
+		Subject: "smime",
+		Part: Part{
+			Type: `multipart/signed; protocol="application/pkcs7-signature"; micalg=sha-256`,
+			Parts: []Part{
+				{Type: "text/plain", Content: "signed"},
+				{Type: "application/pkcs7-signature", TransferEncoding: "base64", Content: "bogus"},
+			},
+		},
+	}, zerom, 0}
+	tdeliver(t, acc, inboxSMIME)
+	state := msgState{acc: acc}
+	smimePM, err := parsedMessage(log, &inboxSMIME.m, &state, true, true, false)
+	state.clear()
+	tcheck(t, err, "parsing s/mime message")
+	tcompare(t, smimePM.smime != nil && !smimePM.smime.Valid && smimePM.smime.Error != "", true)
+	tcompare(t, smimePM.SMIMECertificate == nil, true)
+	smimePM = api.ParsedMessage(ctx, inboxSMIME.ID)
+	tcompare(t, smimePM.SMIMEPinned, "")
+	tneedError(t, func() { api.SMIMEPin(ctx, inboxSMIME.ID) })
+	tneedError(t, func() { api.SMIMEPin(ctx, inboxMinimal.ID) }) // Not signed.
+
+	// Meeting invitation, with event details in the message item, and a reply to the
+	// organizer.
+	ics := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nMETHOD:REQUEST\r\nBEGIN:VEVENT\r\nUID:1@mox.example\r\nDTSTART:20240102T150000Z\r\nSUMMARY:Meeting\r\nORGANIZER;CN=Organizer:mailto:mjl+organizer@mox.example\r\nATTENDEE;CN=mjl;PARTSTAT=NEEDS-ACTION;RSVP=TRUE:mailto:mjl@mox.example\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	inboxInvite := &testmsg{"Inbox", store.Flags{}, nil, Message{
+		From:    "Organizer <mjl+organizer@mox.example>",
+		To:      "mjl <mjl@mox.example>",
+		Subject: "invitation",
+		Part: Part{
+			Type: "multipart/mixed",
+			Parts: []Part{
+				{Type: "text/plain", Content: "you are invited"},
+				{Type: "text/calendar; method=REQUEST", Content: ics},
+			},
+		},
+	}, zerom, 0}
+	tdeliver(t, acc, inboxInvite)
+	state = msgState{acc: acc}
+	mi, err := messageItem(log, inboxInvite.m, &state, nil)
+	state.clear()
+	tcheck(t, err, "message item for invitation")
+	tcompare(t, mi.Calendar != nil, true)
+	tcompare(t, mi.Calendar.Path, []int{1})
+	tcompare(t, mi.Calendar.Method, "REQUEST")
+	tcompare(t, mi.Calendar.Summary, "Meeting")
+	tcompare(t, mi.Calendar.Start.Equal(time.Date(2024, 1, 2, 15, 0, 0, 0, time.UTC)), true)
+	tcompare(t, mi.Calendar.Organizer, CalendarAttendee{Name: "Organizer", Address: "mjl+organizer@mox.example"})
+	tcompare(t, mi.Calendar.Attendees, []CalendarAttendee{{Name: "mjl", Address: "mjl@mox.example", Status: "NEEDS-ACTION"}})
+
+	tneedError(t, func() { api.CalendarReply(ctx, inboxInvite.ID, "MAYBE") })
+	tneedError(t, func() { api.CalendarReply(ctx, inboxMinimal.ID, "ACCEPTED") }) // No invitation.
+	result = api.CalendarReply(ctx, inboxInvite.ID, "ACCEPTED")
+	sentMsg = store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent reply")
+	sentPart, err = sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent reply part")
+	tcompare(t, sentPart.Envelope.Subject, "Accepted: Meeting")
+	tcompare(t, len(sentPart.Parts), 2)
+	icsPart := sentPart.Parts[1]
+	tcompare(t, icsPart.MediaType+"/"+icsPart.MediaSubType, "TEXT/CALENDAR")
+	tcompare(t, icsPart.ContentTypeParams["method"], "REPLY")
+	buf, err := io.ReadAll(icsPart.Reader())
+	tcheck(t, err, "read reply calendar")
+	tcompare(t, strings.Contains(string(buf), "METHOD:REPLY\r\n"), true)
+	tcompare(t, strings.Contains(string(buf), "ATTENDEE;CN=mjl;PARTSTAT=ACCEPTED:mailto:mjl@mox.example\r\n"), true)
+
+	// Mailing list message with List-Unsubscribe, unsubscribing with a message.
+	inboxList := &testmsg{"Inbox", store.Flags{}, nil, Message{
+		From:    "List <list@remote.example>",
+		To:      "mjl <mjl@mox.example>",
+		Subject: "newsletter",
+		Headers: [][2]string{
+			{"List-Id", "<list.remote.example>"},
+			{"List-Unsubscribe", "<mailto:mjl+unsubscribe@mox.example?subject=leave>"},
+		},
+		Part: Part{Type: "text/plain", Content: "news"},
+	}, zerom, 0}
+	tdeliver(t, acc, inboxList)
+	state = msgState{acc: acc}
+	mi, err = messageItem(log, inboxList.m, &state, nil)
+	state.clear()
+	tcheck(t, err, "message item for list message")
+	tcompare(t, mi.Unsubscribe, &ListUnsubscribe{Mailto: "mailto:mjl+unsubscribe@mox.example?subject=leave"})
+	tneedError(t, func() { api.MessageUnsubscribe(ctx, inboxList.ID, "oneclick") })
+	tneedError(t, func() { api.MessageUnsubscribe(ctx, inboxMinimal.ID, "mailto") }) // No List-Unsubscribe.
+	unsub := api.MessageUnsubscribe(ctx, inboxList.ID, "mailto")
+	tcompare(t, unsub.Target, "mjl+unsubscribe@mox.example")
+	tcompare(t, unsub.ListID, "list.remote.example")
+	history := api.UnsubscribeHistory(ctx, "List@Remote.example")
+	tcompare(t, len(history), 1)
+	tcompare(t, history[0].ID, unsub.ID)
+	tcompare(t, history[0].Error, "")
+
+	// Rules, run on existing messages in the inbox.
+	tneedError(t, func() { api.RuleSave(ctx, store.Rule{Name: " ", Mailbox: "Archive"}) })               // Missing name.
+	tneedError(t, func() { api.RuleSave(ctx, store.Rule{Name: "x"}) })                                   // No action.
+	tneedError(t, func() { api.RuleSave(ctx, store.Rule{Name: "x", Mailbox: "Absent"}) })                // Unknown mailbox.
+	tneedError(t, func() { api.RuleSave(ctx, store.Rule{Name: "x", ForwardTo: []string{"bogus"}}) })     // Bad address.
+	tneedError(t, func() { api.RuleSave(ctx, store.Rule{Name: "x", Mailbox: "Archive", Delete: true}) }) // Move and delete.
+	tneedError(t, func() { api.RuleSave(ctx, store.Rule{ID: 999, Name: "x", Mailbox: "Archive"}) })      // Does not exist.
+	rule := api.RuleSave(ctx, store.Rule{Name: "lists", ListID: "list.remote.example", Mailbox: "Archive", ForwardTo: []string{" Other@Remote.example "}})
+	tcompare(t, rule.ID != 0, true)
+	tcompare(t, rule.ForwardTo, []string{"Other@remote.example"})
+	rule.MarkRead = true
+	api.RuleSave(ctx, rule)
+	tcompare(t, api.Rules(ctx), []store.Rule{rule})
+	tcompare(t, api.RuleRun(ctx, inbox.ID), 1)
+	listMsg := store.Message{ID: inboxList.ID}
+	err = acc.DB.Get(ctx, &listMsg)
+	tcheck(t, err, "get message")
+	tcompare(t, listMsg.MailboxID != inbox.ID, true)
+	tcompare(t, listMsg.Seen, true)
+	tneedError(t, func() { api.RuleRun(ctx, 999) })
+	api.RuleRemove(ctx, rule.ID)
+	tneedError(t, func() { api.RuleRemove(ctx, rule.ID) })
+	tcompare(t, len(api.Rules(ctx)), 0)
+
+	// Saved searches, with counts of matching messages.
+	tneedError(t, func() { api.SavedSearchSave(ctx, SavedSearch{Name: " "}) })                                        // Missing name.
+	tneedError(t, func() { api.SavedSearchSave(ctx, SavedSearch{Name: "x", Filter: Filter{MailboxName: "Absent"}}) }) // Unknown mailbox.
+	tneedError(t, func() { api.SavedSearchSave(ctx, SavedSearch{ID: 999, Name: "x"}) })                               // Does not exist.
+	var inboxTotal, inboxUnseen int
+	err = bstore.QueryDB[store.Message](ctx, acc.DB).FilterNonzero(store.Message{MailboxID: inbox.ID}).FilterEqual("Expunged", false).ForEach(func(m store.Message) error {
+		inboxTotal++
+		if !m.Seen {
+			inboxUnseen++
+		}
+		return nil
+	})
+	tcheck(t, err, "counting inbox messages")
+	ss := api.SavedSearchSave(ctx, SavedSearch{Name: " inbox ", Search: "mb:Inbox", Filter: Filter{MailboxName: "Inbox"}, Total: 123})
+	tcompare(t, ss.ID != 0, true)
+	tcompare(t, ss.Name, "inbox")
+	tcompare(t, ss.Filter.MailboxID, inbox.ID)
+	tcompare(t, [2]int{ss.Total, ss.Unseen}, [2]int{inboxTotal, inboxUnseen})
+	tneedError(t, func() { api.SavedSearchSave(ctx, SavedSearch{Name: "inbox"}) }) // Duplicate name.
+	ssNone := api.SavedSearchSave(ctx, SavedSearch{Name: "none", Search: "nomatchanywhere", Filter: Filter{MailboxID: -1, Words: []string{"nomatchanywhere"}}})
+	tcompare(t, ssNone.Total, 0)
+	tcompare(t, api.SavedSearches(ctx), []SavedSearch{ss, ssNone})
+	ssNone.Name = "nothing"
+	ssNone = api.SavedSearchSave(ctx, ssNone)
+	tcompare(t, api.SavedSearches(ctx), []SavedSearch{ss, ssNone})
+	api.SavedSearchRemove(ctx, ssNone.ID)
+	tneedError(t, func() { api.SavedSearchRemove(ctx, ssNone.ID) })
+	api.SavedSearchRemove(ctx, ss.ID)
+	tcompare(t, len(api.SavedSearches(ctx)), 0)
+
+	// Bulk operations on messages matching a query.
+	inboxQuery := Query{Filter: Filter{MailboxID: inbox.ID}}
+	tneedError(t, func() { api.MessageBulk(ctx, 0, inboxQuery, BulkAction{Op: "bogus"}) })
+	tneedError(t, func() { api.MessageBulk(ctx, 0, inboxQuery, BulkAction{Op: BulkLabel}) })                // Missing label.
+	tneedError(t, func() { api.MessageBulk(ctx, 0, inboxQuery, BulkAction{Op: BulkMove, MailboxID: 999}) }) // Unknown mailbox.
+	tneedError(t, func() { api.MessageBulk(ctx, 999, inboxQuery, BulkAction{Op: BulkMarkRead}) })           // Unknown sse.
+	bulkSSE := sseRegister("mjl")
+	tcompare(t, api.MessageBulk(ctx, bulkSSE.ID, inboxQuery, BulkAction{Op: BulkMarkRead}), inboxUnseen)
+	if inboxUnseen > 0 {
+		tcompare(t, <-bulkSSE.Bulk, EventBulkProgress{BulkMarkRead, inboxUnseen, inboxUnseen})
+	}
+	bulkSSE.unregister()
+	tcompare(t, api.MessageBulk(ctx, 0, inboxQuery, BulkAction{Op: BulkMarkRead}), 0)
+	tcompare(t, api.MessageBulk(ctx, 0, inboxQuery, BulkAction{Op: BulkMarkUnread}), inboxTotal)
+	tcompare(t, api.MessageBulk(ctx, 0, inboxQuery, BulkAction{Op: BulkLabel, Label: "bulk"}), inboxTotal)
+	nlabeled, err := bstore.QueryDB[store.Message](ctx, acc.DB).FilterNonzero(store.Message{MailboxID: inbox.ID}).FilterEqual("Expunged", false).FilterFn(func(m store.Message) bool { return slices.Contains(m.Keywords, "bulk") && !m.Seen }).Count()
+	tcheck(t, err, "counting labeled messages")
+	tcompare(t, nlabeled, inboxTotal)
+	tcompare(t, api.MessageBulk(ctx, 0, Query{Filter: Filter{MailboxID: inbox.ID, Labels: []string{"bulk"}}}, BulkAction{Op: BulkMove, MailboxID: archive.ID}), inboxTotal)
+	tcompare(t, api.MessageBulk(ctx, 0, Query{Filter: Filter{MailboxID: archive.ID, Labels: []string{"bulk"}}}, BulkAction{Op: BulkMove, MailboxID: inbox.ID}), inboxTotal)
+	tcompare(t, api.MessageBulk(ctx, 0, Query{Filter: Filter{MailboxID: -1, Words: []string{"nomatchanywhere"}}}, BulkAction{Op: BulkDelete}), 0)
+
+	// Labels, with renames and removals changing messages.
+	labelSettings := func() []store.Label {
+		t.Helper()
+		settings := store.Settings{ID: 1}
+		err := acc.DB.Get(ctx, &settings)
+		tcheck(t, err, "get settings")
+		return settings.Labels
+	}
+	nkeyword := func(kw string) int {
+		t.Helper()
+		n, err := bstore.QueryDB[store.Message](ctx, acc.DB).FilterEqual("Expunged", false).FilterFn(func(m store.Message) bool { return slices.Contains(m.Keywords, kw) }).Count()
+		tcheck(t, err, "counting messages with keyword")
+		return n
+	}
+	tneedError(t, func() { api.LabelSave(ctx, store.Label{Name: "a b"}) })             // Invalid keyword.
+	tneedError(t, func() { api.LabelSave(ctx, store.Label{Name: "$junk"}) })           // Reserved.
+	tneedError(t, func() { api.LabelSave(ctx, store.Label{Name: "a//b"}) })            // Empty level.
+	tneedError(t, func() { api.LabelSave(ctx, store.Label{Name: "x", Color: "red"}) }) // Bad color.
+	tcompare(t, api.LabelSave(ctx, store.Label{Name: " Bulk ", Color: "#FF0000"}), store.Label{Name: "bulk", Color: "#ff0000"})
+	api.LabelSave(ctx, store.Label{Name: "bulk/sub"})
+	api.LabelSave(ctx, store.Label{Name: "other"})
+	api.LabelSave(ctx, store.Label{Name: "bulk", Color: "#00ff00"})
+	tcompare(t, labelSettings(), []store.Label{{Name: "bulk", Color: "#00ff00"}, {Name: "bulk/sub"}, {Name: "other"}})
+	tneedError(t, func() { api.LabelsOrder(ctx, []string{"other", "bulk"}) })          // Missing label.
+	tneedError(t, func() { api.LabelsOrder(ctx, []string{"other", "bulk", "other"}) }) // Duplicate.
+	api.LabelsOrder(ctx, []string{"other", "bulk", "bulk/sub"})
+	tcompare(t, labelSettings(), []store.Label{{Name: "other"}, {Name: "bulk", Color: "#00ff00"}, {Name: "bulk/sub"}})
+	// Saving settings leaves labels alone.
+	api.SettingsSave(ctx, store.Settings{})
+	tcompare(t, len(labelSettings()), 3)
+
+	tneedError(t, func() { api.LabelRename(ctx, "bulk", "bulk/below") }) // Below itself.
+	tcompare(t, api.LabelRename(ctx, "bulk", "work/bulk"), inboxTotal)
+	tcompare(t, labelSettings(), []store.Label{{Name: "other"}, {Name: "work/bulk", Color: "#00ff00"}, {Name: "work/bulk/sub"}})
+	tcompare(t, nkeyword("bulk"), 0)
+	tcompare(t, nkeyword("work/bulk"), inboxTotal)
+	xinbox, err := bstore.QueryDB[store.Mailbox](ctx, acc.DB).FilterNonzero(store.Mailbox{ID: inbox.ID}).Get()
+	tcheck(t, err, "get inbox")
+	tcompare(t, slices.Contains(xinbox.Keywords, "bulk"), false)
+	tcompare(t, slices.Contains(xinbox.Keywords, "work/bulk"), true)
+	// Merging into an existing label.
+	tcompare(t, api.LabelRename(ctx, "work", "other"), inboxTotal)
+	tcompare(t, labelSettings(), []store.Label{{Name: "other"}, {Name: "other/bulk", Color: "#00ff00"}, {Name: "other/bulk/sub"}})
+	tcompare(t, api.LabelRemove(ctx, "other"), inboxTotal)
+	tcompare(t, len(labelSettings()), 0)
+	tcompare(t, nkeyword("other/bulk"), 0)
+
+	// Web Push subscriptions and notification settings.
+	pushKey := api.WebPushKey(ctx)
+	tcompare(t, api.WebPushKey(ctx), pushKey)
+	uaKey, err := ecdh.P256().GenerateKey(cryptorand.Reader)
+	tcheck(t, err, "generate key")
+	p256dh := base64.RawURLEncoding.EncodeToString(uaKey.PublicKey().Bytes())
+	pushAuth := base64.RawURLEncoding.EncodeToString([]byte("0123456789abcdef"))
+	tneedError(t, func() { api.WebPushSubscribe(ctx, "http://push.example/1", p256dh, pushAuth) }) // Not https.
+	tneedError(t, func() { api.WebPushSubscribe(ctx, "https://push.example/1", "bogus", pushAuth) })
+	api.WebPushSubscribe(ctx, "https://push.example/1", p256dh, pushAuth)
+	api.WebPushSubscribe(ctx, "https://push.example/1", p256dh, pushAuth) // Replaces.
+	for i := range webpush.MaxSubscriptions {
+		api.WebPushSubscribe(ctx, fmt.Sprintf("https://push.example/x%d", i), p256dh, pushAuth)
+	}
+	npush := func() int {
+		t.Helper()
+		n, err := bstore.QueryDB[store.WebPushSubscription](ctx, acc.DB).Count()
+		tcheck(t, err, "count web push subscriptions")
+		return n
+	}
+	tcompare(t, npush(), webpush.MaxSubscriptions)
+	api.WebPushUnsubscribe(ctx, "https://push.example/x0")
+	tcompare(t, npush(), webpush.MaxSubscriptions-1)
+	tneedError(t, func() { api.SettingsSave(ctx, store.Settings{NotifySenders: []string{"bogus"}}) })
+	api.SettingsSave(ctx, store.Settings{NotifyMailboxes: []string{"Inbox", "Lists"}, NotifySenders: []string{" Boss@Work.example", "@friends.example"}})
+	settings = store.Settings{ID: 1}
+	err = acc.DB.Get(ctx, &settings)
+	tcheck(t, err, "get settings")
+	tcompare(t, settings.NotifySenders, []string{"boss@work.example", "@friends.example"})
+
+	// Delegation of access to mailboxes to another account.
+	tneedError(t, func() { api.DelegationSave(ctx, config.Delegation{Account: "mjl", Mailboxes: []string{"Inbox"}}) })   // Own account.
+	tneedError(t, func() { api.DelegationSave(ctx, config.Delegation{Account: "bogus", Mailboxes: []string{"Inbox"}}) }) // Unknown account.
+	tneedError(t, func() { api.DelegationSave(ctx, config.Delegation{Account: "other", Mailboxes: []string{" "}}) })     // No mailbox.
+	tneedError(t, func() {
+		api.DelegationSave(ctx, config.Delegation{Account: "other", Mailboxes: []string{"Inbox"}, Send: "bogus"})
+	})
+	api.DelegationSave(ctx, config.Delegation{Account: "other", Mailboxes: []string{" Inbox ", "Inbox"}})
+	given, received := api.Delegations(ctx)
+	tcompare(t, given, []config.Delegation{{Account: "other", Mailboxes: []string{"Inbox"}}})
+	tcompare(t, len(received), 0)
+	api.DelegationRemove(ctx, "other")
+	tneedError(t, func() { api.DelegationRemove(ctx, "other") })
+
+	// Access to mailboxes of the other account, given to this account.
+	oacc, err := store.OpenAccount(log, "other", false)
+	tcheck(t, err, "open other account")
+	defer func() {
+		err := oacc.Close()
+		log.Check(err, "closing account")
+	}()
+	otherMsg := &testmsg{"Inbox", store.Flags{}, nil, Message{
+		From:    "Customer <customer@remote.example>",
+		To:      "other@mox.example",
+		Subject: "help",
+		Part:    Part{Type: "text/plain", Content: "please help"},
+	}, zerom, 0}
+	tdeliver(t, oacc, otherMsg)
+	delegate := func(d *config.Delegation) {
+		t.Helper()
+		err := admin.AccountSave(ctxbg, "other", func(accConf *config.Account) {
+			accConf.Delegations = nil
+			if d != nil {
+				accConf.Delegations = []config.Delegation{*d}
+			}
+		})
+		tcheck(t, err, "saving delegation")
+	}
+	delegate(&config.Delegation{Account: "mjl", Mailboxes: []string{"Inbox", "Archive"}, Send: "onbehalf"})
+	_, received = api.Delegations(ctx)
+	tcompare(t, received, []DelegatedAccess{{"other", []string{"Inbox", "Archive"}, false, "onbehalf", []string{"other@mox.example"}}})
+	items := api.DelegatedMessages(ctx, "other", "Inbox", 0, 10)
+	tcompare(t, len(items), 1)
+	tcompare(t, items[0].Message.ID, otherMsg.ID)
+	tcompare(t, len(api.DelegatedMessages(ctx, "other", "Inbox", 1, 10)), 0)
+	tcompare(t, len(api.DelegatedMessages(ctx, "other", "Archive", 0, 10)), 0)
+	tneedError(t, func() { api.DelegatedMessages(ctx, "other", "Sent", 0, 10) })     // Not delegated.
+	tneedError(t, func() { api.DelegatedMessages(ctx, "disabled", "Inbox", 0, 10) }) // No access.
+	pm = api.DelegatedParsedMessage(ctx, "other", otherMsg.ID)
+	tcompare(t, strings.TrimSpace(pm.Texts[0]), "please help")
+	tneedError(t, func() { api.DelegatedParsedMessage(ctx, "other", otherMsg.ID+1000) })
+	tneedError(t, func() { api.DelegatedFlagsAdd(ctx, "other", []int64{otherMsg.ID}, []string{`\seen`}) }) // Read-only.
+
+	delegate(&config.Delegation{Account: "mjl", Mailboxes: []string{"Inbox", "Archive"}, Write: true, Send: "onbehalf"})
+	api.DelegatedFlagsAdd(ctx, "other", []int64{otherMsg.ID}, []string{`\seen`})
+	otherM := store.Message{ID: otherMsg.ID}
+	err = oacc.DB.Get(ctx, &otherM)
+	tcheck(t, err, "get message")
+	tcompare(t, otherM.Seen, true)
+	api.DelegatedFlagsClear(ctx, "other", []int64{otherMsg.ID}, []string{`\seen`})
+	tneedError(t, func() { api.DelegatedMessageMove(ctx, "other", []int64{otherMsg.ID}, "Sent") })
+	api.DelegatedMessageMove(ctx, "other", []int64{otherMsg.ID}, "Archive")
+	tcompare(t, len(api.DelegatedMessages(ctx, "other", "Archive", 0, 10)), 1)
+
+	// Messages outside the delegated mailboxes cannot be changed.
+	delegate(&config.Delegation{Account: "mjl", Mailboxes: []string{"Inbox"}, Write: true, Send: "onbehalf"})
+	tneedError(t, func() { api.DelegatedFlagsAdd(ctx, "other", []int64{otherMsg.ID}, []string{`\seen`}) })
+	tneedError(t, func() { api.DelegatedMessageMove(ctx, "other", []int64{otherMsg.ID}, "Inbox") })
+	tneedError(t, func() { api.DelegatedMessageDelete(ctx, "other", []int64{otherMsg.ID}) })
+	err = oacc.DB.Get(ctx, &otherM)
+	tcheck(t, err, "get message")
+	tcompare(t, otherM.Seen, false)
+	tcompare(t, otherM.Expunged, false)
+	tcompare(t, len(api.DelegatedMessages(ctx, "other", "Inbox", 0, 10)), 0)
+	delegate(&config.Delegation{Account: "mjl", Mailboxes: []string{"Inbox", "Archive"}, Write: true, Send: "onbehalf"})
+
+	api.DelegatedMessageDelete(ctx, "other", []int64{otherMsg.ID})
+	tcompare(t, len(api.DelegatedMessages(ctx, "other", "Archive", 0, 10)), 0)
+
+	// Sending on behalf of the other account adds a Sender header.
+	result = api.MessageSubmit(ctx, SubmitMessage{
+		From:     "other@mox.example",
+		To:       []string{"mjl+to@mox.example"},
+		TextBody: "test",
+	})
+	sentMsg = store.Message{ID: result.SentMessageID}
+	err = acc.DB.Get(ctx, &sentMsg)
+	tcheck(t, err, "get sent message")
+	sentPart, err = sentMsg.LoadPart(acc.MessageReader(sentMsg))
+	tcheck(t, err, "load sent message part")
+	h, err := sentPart.Header()
+	tcheck(t, err, "parse header")
+	tcompare(t, h.Get("Sender"), "<mjl@mox.example>")
+	delegate(nil)
+
 	// Send without special-use Sent mailbox.
 	api.MailboxSetSpecialUse(ctx, store.Mailbox{ID: sent.ID, SpecialUse: store.SpecialUse{}})
 	api.MessageSubmit(ctx, SubmitMessage{
@@ -514,6 +1241,16 @@ func TestAPI(t *testing.T) {
 	tcompare(t, l, []string{`"mjl, cc2" <mjl+cc2@mox.example>`, "mjl bcc2 <mjl+bcc2@mox.example>"})
 	tcompare(t, full, true)
 
+	// Contacts from address books are completed too, matching on name.
+	ab := store.AddressBook{Name: "test"}
+	err = acc.DB.Insert(ctx, &ab)
+	tcheck(t, err, "insert address book")
+	err = acc.DB.Insert(ctx, &store.Contact{AddressBookID: ab.ID, Name: "c.vcf", UID: "c", FullName: "Contact Person", Emails: []string{"contact@other.example"}})
+	tcheck(t, err, "insert contact")
+	l, full = api.CompleteRecipient(ctx, "person")
+	tcompare(t, l, []string{"Contact Person <contact@other.example>"})
+	tcompare(t, full, true)
+
 	// RecipientSecurity
 	resolver := dns.MockResolver{}
 	rs, err := recipientSecurity(ctx, log, resolver, "mjl@a.mox.example")
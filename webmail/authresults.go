@@ -0,0 +1,131 @@
+package webmail
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/bimi"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+// AuthResults summarizes the message authentication checks done by mox when the
+// message was delivered, for showing pass/fail indicators. Only the headers mox
+// added during delivery are used, headers in the message itself could have been
+// added by anyone.
+type AuthResults struct {
+	IPRev AuthCheck   // Reverse DNS lookup of the remote IP, without domain.
+	SPF   AuthCheck   // With MAIL FROM domain, or EHLO domain if MAIL FROM was empty.
+	DKIM  []AuthCheck // One per DKIM-Signature header, with signing domain.
+	DMARC AuthCheck   // With domain of message From header. Result empty if domain has no DMARC record.
+	BIMI  AuthCheck   // With domain of BIMI record. Result empty if not evaluated.
+
+	// For messages that passed BIMI evaluation, the URL of the logo of the sender
+	// domain, and the path relative to the webmail for loading it through mox.
+	BIMILogoURL  string
+	BIMILogoPath string
+
+	// Reason for the delivery decision, e.g. "junk-content" or "dmarc-policy" for
+	// messages delivered to the Junk mailbox, with human-readable details.
+	Reason        string
+	ReasonDetails []string
+}
+
+// AuthCheck is the result of an authentication check.
+type AuthCheck struct {
+	Result string // E.g. "pass", "fail", "softfail", "none", "temperror".
+	Domain string
+}
+
+// parseAuthResults returns the authentication results from the headers added by
+// mox during delivery, or nil for messages that were not delivered over SMTP,
+// e.g. sent or imported messages. If the results were stored with the message,
+// they are used instead of the Authentication-Results header.
+func parseAuthResults(log mlog.Log, msgPrefix []byte, stored *store.MessageAuth) *AuthResults {
+	if len(msgPrefix) == 0 {
+		return nil
+	}
+	r := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(msgPrefix[:len(msgPrefix):len(msgPrefix)], "\r\n"...))))
+	h, err := r.ReadMIMEHeader()
+	if err != nil {
+		log.Debugx("parsing delivery headers for authentication results", err)
+		return nil
+	}
+
+	a := AuthResults{DKIM: []AuthCheck{}}
+	if stored != nil {
+		a.IPRev = AuthCheck{Result: stored.IPRev}
+		a.SPF = AuthCheck{stored.SPF, stored.SPFDomain}
+		for _, d := range stored.DKIM {
+			if d.Status != "none" {
+				a.DKIM = append(a.DKIM, AuthCheck{d.Status, d.Domain})
+			}
+		}
+		a.DMARC = AuthCheck{stored.DMARC, stored.DMARCDomain}
+		a.BIMI = AuthCheck{stored.BIMI, stored.BIMIDomain}
+	} else {
+		v := h.Get("Authentication-Results")
+		if v == "" {
+			return nil
+		}
+		ar, err := message.ParseAuthResults(v + "\r\n")
+		if err != nil {
+			log.Debugx("parsing authentication-results header", err)
+			return nil
+		}
+
+		prop := func(m message.AuthMethod, typ string, props ...string) string {
+			for _, p := range m.Props {
+				for _, k := range props {
+					if strings.EqualFold(p.Type, typ) && strings.EqualFold(p.Property, k) {
+						return p.Value
+					}
+				}
+			}
+			return ""
+		}
+
+		for _, m := range ar.Methods {
+			switch strings.ToLower(m.Method) {
+			case "iprev":
+				a.IPRev = AuthCheck{Result: m.Result}
+			case "spf":
+				a.SPF = AuthCheck{m.Result, prop(m, "smtp", "mailfrom", "helo")}
+			case "dkim":
+				if m.Result != "none" {
+					a.DKIM = append(a.DKIM, AuthCheck{m.Result, prop(m, "header", "d")})
+				}
+			case "dmarc":
+				a.DMARC = AuthCheck{m.Result, prop(m, "header", "from")}
+			case "bimi":
+				a.BIMI = AuthCheck{m.Result, prop(m, "header", "d")}
+			}
+		}
+	}
+
+	// The BIMI-Location header is only added by mox for a passing BIMI evaluation.
+	if v := h.Get("BIMI-Location"); v != "" && a.BIMI.Result == "pass" {
+		if r, _, err := bimi.ParseRecord(v); err != nil {
+			log.Debugx("parsing bimi-location header", err)
+		} else if r.Location != "" {
+			a.BIMILogoURL = r.Location
+			a.BIMILogoPath = bimiLogoPath(r.Location, time.Now())
+		}
+	}
+
+	// E.g. "X-Mox-Reason: junk-content; details; more details;".
+	if reason := h.Get("X-Mox-Reason"); reason != "" {
+		t := strings.Split(reason, ";")
+		a.Reason = strings.TrimSpace(t[0])
+		for _, s := range t[1:] {
+			if s = strings.TrimSpace(s); s != "" {
+				a.ReasonDetails = append(a.ReasonDetails, s)
+			}
+		}
+	}
+	return &a
+}
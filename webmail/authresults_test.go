@@ -0,0 +1,84 @@
+package webmail
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+func TestParseAuthResults(t *testing.T) {
+	log := mlog.New("webmail", nil)
+
+	check := func(prefix string, exp *AuthResults) {
+		t.Helper()
+		tcompare(t, parseAuthResults(log, []byte(prefix), nil), exp)
+	}
+
+	check("", nil)
+	check("Delivered-To: mjl@mox.example\r\nReturn-Path: <remote@example.org>\r\n", nil)
+
+	prefix := "X-Mox-Reason: junk-content; content classified as junk with probability 0.95;\r\n" +
+		"\tsender address has no reputation;\r\n" +
+		"Delivered-To: mjl@mox.example\r\n" +
+		"Return-Path: <remote@example.org>\r\n" +
+		"Authentication-Results: mox.example; iprev=pass policy.iprev=10.0.0.1;\r\n" +
+		"\tdkim=pass header.d=example.org header.s=sel header.a=ed25519-sha256\r\n" +
+		"\theader.b=AbCdEf; dkim=fail header.d=other.example header.s=sel;\r\n" +
+		"\tspf=softfail smtp.mailfrom=example.org; dmarc=pass header.from=example.org\r\n" +
+		"Received-SPF: softfail client-ip=10.0.0.1;\r\n"
+	check(prefix, &AuthResults{
+		IPRev: AuthCheck{"pass", ""},
+		SPF:   AuthCheck{"softfail", "example.org"},
+		DKIM: []AuthCheck{
+			{"pass", "example.org"},
+			{"fail", "other.example"},
+		},
+		DMARC:         AuthCheck{"pass", "example.org"},
+		Reason:        "junk-content",
+		ReasonDetails: []string{"content classified as junk with probability 0.95", "sender address has no reputation"},
+	})
+
+	// No DKIM signatures, SPF for EHLO domain.
+	check("Authentication-Results: mox.example; iprev=fail policy.iprev=10.0.0.1; dkim=none;\r\n\tspf=none smtp.helo=host.example.org; dmarc=none header.from=example.org\r\n", &AuthResults{
+		IPRev: AuthCheck{"fail", ""},
+		SPF:   AuthCheck{"none", "host.example.org"},
+		DKIM:  []AuthCheck{},
+		DMARC: AuthCheck{"none", "example.org"},
+	})
+
+	// BIMI pass with logo location.
+	a := parseAuthResults(log, []byte("BIMI-Location: v=BIMI1; l=https://example.org/logo.svg\r\nAuthentication-Results: mox.example; dmarc=pass header.from=example.org;\r\n\tbimi=pass header.d=example.org header.selector=default\r\n"), nil)
+	if a == nil || a.BIMI != (AuthCheck{"pass", "example.org"}) || a.BIMILogoURL != "https://example.org/logo.svg" || !strings.HasPrefix(a.BIMILogoPath, "bimilogo?") {
+		t.Fatalf("unexpected bimi auth results %#v", a)
+	}
+
+	// BIMI-Location header is ignored without bimi pass.
+	a = parseAuthResults(log, []byte("BIMI-Location: v=BIMI1; l=https://example.org/logo.svg\r\nAuthentication-Results: mox.example; dmarc=pass header.from=example.org\r\n"), nil)
+	if a == nil || a.BIMILogoURL != "" || a.BIMILogoPath != "" {
+		t.Fatalf("unexpected bimi logo without bimi pass %#v", a)
+	}
+
+	// Stored results are used instead of the Authentication-Results header.
+	stored := &store.MessageAuth{
+		IPRev:       "pass",
+		SPF:         "pass",
+		SPFDomain:   "example.org",
+		DKIM:        []store.MessageAuthDKIM{{Status: "pass", Domain: "example.org"}},
+		DMARC:       "pass",
+		DMARCDomain: "example.org",
+		BIMI:        "none",
+		BIMIDomain:  "example.org",
+	}
+	a = parseAuthResults(log, []byte(prefix), stored)
+	tcompare(t, a, &AuthResults{
+		IPRev:         AuthCheck{"pass", ""},
+		SPF:           AuthCheck{"pass", "example.org"},
+		DKIM:          []AuthCheck{{"pass", "example.org"}},
+		DMARC:         AuthCheck{"pass", "example.org"},
+		BIMI:          AuthCheck{"none", "example.org"},
+		Reason:        "junk-content",
+		ReasonDetails: []string{"content classified as junk with probability 0.95", "sender address has no reputation"},
+	})
+}
@@ -0,0 +1,111 @@
+package webmail
+
+// BIMI logos of senders, from the BIMI-Location header added during delivery for
+// messages that passed DMARC with an enforced policy. Logos are fetched by mox,
+// like remote content through the proxy, so senders don't learn when and where
+// a message is read. URLs are signed with the proxy key.
+
+import (
+	"context"
+	"crypto/hmac"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+)
+
+// Maximum size of BIMI logos.
+const bimiLogoMaxSize = 32 * 1024
+
+// bimiLogoPath returns the path, relative to the webmail, for loading the BIMI
+// logo at u through mox. An empty string is returned for URLs that cannot be
+// loaded.
+func bimiLogoPath(u string, now time.Time) string {
+	pu, err := url.Parse(u)
+	if err != nil || pu.Scheme != "https" || pu.Host == "" {
+		return ""
+	}
+	expires := fmt.Sprintf("%d", now.Add(proxyURLValidity).Unix())
+	v := url.Values{"u": {u}, "e": {expires}, "s": {proxySign("bimi", u, expires)}}
+	return "bimilogo?" + v.Encode()
+}
+
+// bimiFetch fetches the SVG logo at u.
+func bimiFetch(ctx context.Context, u string) (proxyEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return proxyEntry{}, err
+	}
+	req.Header.Set("User-Agent", "mox")
+	req.Header.Set("Accept", "image/svg+xml")
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		return proxyEntry{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return proxyEntry{}, fmt.Errorf("remote responded with status %d", resp.StatusCode)
+	}
+	ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || ct != "image/svg+xml" {
+		return proxyEntry{}, fmt.Errorf("remote responded with unsupported content-type %q", resp.Header.Get("Content-Type"))
+	}
+	data, err := io.ReadAll(&moxio.LimitReader{R: resp.Body, Limit: bimiLogoMaxSize})
+	if err != nil {
+		return proxyEntry{}, fmt.Errorf("reading logo: %w", err)
+	}
+	return proxyEntry{contentType: ct, data: data}, nil
+}
+
+// serveBIMILogo serves a request for a BIMI logo, with a URL signed by
+// bimiLogoPath.
+func serveBIMILogo(ctx context.Context, log mlog.Log, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	u, expires, sig := q.Get("u"), q.Get("e"), q.Get("s")
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || !hmac.Equal([]byte(sig), []byte(proxySign("bimi", u, expires))) {
+		http.Error(w, "403 - forbidden - bad signature", http.StatusForbidden)
+		return
+	}
+	now := time.Now()
+	if now.Unix() > exp {
+		http.Error(w, "403 - forbidden - logo url expired", http.StatusForbidden)
+		return
+	}
+
+	// Cached along with proxied resources, with a key that cannot be a proxied URL.
+	key := "bimi:" + u
+	e, ok := proxyCacheGet(key, now)
+	if !ok {
+		e, err = bimiFetch(ctx, u)
+		if err != nil {
+			log.Debugx("fetching bimi logo", err, slog.String("url", u))
+			http.Error(w, "502 - bad gateway - fetching logo failed", http.StatusBadGateway)
+			return
+		}
+		e.expires = now.Add(proxyCacheTTL)
+		proxyCacheAdd(key, e, now)
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", e.contentType)
+	h.Set("Content-Length", fmt.Sprintf("%d", len(e.data)))
+	h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(proxyCacheTTL.Seconds())))
+	// SVG images can contain scripts, they are not executed when shown as image, and
+	// this policy prevents them when opened directly.
+	h.Set("Content-Security-Policy", "sandbox; default-src 'none'; style-src 'unsafe-inline'")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "no-referrer")
+	if r.Method == "HEAD" {
+		return
+	}
+	_, err = w.Write(e.data)
+	log.Check(err, "writing bimi logo")
+}
@@ -0,0 +1,64 @@
+package webmail
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/mox/mlog"
+)
+
+func TestBIMILogo(t *testing.T) {
+	now := time.Now()
+
+	tcompare(t, bimiLogoPath("http://mox.example/logo.svg", now), "")
+	tcompare(t, bimiLogoPath("/logo.svg", now), "")
+
+	var requests int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch r.URL.Path {
+		case "/logo.svg":
+			w.Header().Set("Content-Type", "image/svg+xml")
+			w.Write([]byte("<svg/>"))
+		case "/logo.png":
+			w.Header().Set("Content-Type", "image/png")
+			w.Write([]byte("png"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	origClient := proxyClient
+	defer func() { proxyClient = origClient }()
+	proxyClient = srv.Client()
+
+	log := mlog.New("webmail", nil)
+	serve := func(p string, expCode int) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/webmail/"+p, nil)
+		rw := httptest.NewRecorder()
+		serveBIMILogo(ctxbg, log, rw, req)
+		if rw.Code != expCode {
+			t.Fatalf("got status %d, expected %d, body %q", rw.Code, expCode, rw.Body.String())
+		}
+		return rw
+	}
+
+	rw := serve(bimiLogoPath(srv.URL+"/logo.svg", now), http.StatusOK)
+	tcompare(t, rw.Body.String(), "<svg/>")
+	tcompare(t, rw.Header().Get("Content-Type"), "image/svg+xml")
+	serve(bimiLogoPath(srv.URL+"/logo.svg", now), http.StatusOK)
+	tcompare(t, requests, 1) // Second from cache.
+
+	serve(bimiLogoPath(srv.URL+"/logo.png", now), http.StatusBadGateway) // Only svg.
+	serve(bimiLogoPath(srv.URL+"/missing.svg", now), http.StatusBadGateway)
+
+	// Proxy URLs are not valid as logo URLs, bad signature and expired URL.
+	serve(strings.Replace(proxySession{"mjl", 1}.proxyURL(nil, srv.URL+"/logo.svg", now), "../../proxy", "bimilogo", 1), http.StatusForbidden)
+	serve(strings.Replace(bimiLogoPath(srv.URL+"/logo.svg", now), "logo.svg", "other.svg", 1), http.StatusForbidden)
+	serve(bimiLogoPath(srv.URL+"/logo.svg", now.Add(-2*proxyURLValidity)), http.StatusForbidden)
+}
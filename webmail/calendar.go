@@ -0,0 +1,151 @@
+package webmail
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/ical"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/moxvar"
+)
+
+// CalendarInvite is an event from a text/calendar part of a message, typically a
+// meeting invitation (method REQUEST), or a reply to an invitation.
+type CalendarInvite struct {
+	Path        []int  // Of the text/calendar part in the message.
+	Method      string // Upper case, e.g. REQUEST, REPLY, CANCEL. Empty for calendar data that isn't a scheduling message.
+	UID         string
+	Sequence    int
+	Summary     string
+	Location    string
+	Description string
+	Start       time.Time
+	End         *time.Time
+	AllDay      bool // If set, Start and End are dates, at midnight UTC.
+	Organizer   CalendarAttendee
+	Attendees   []CalendarAttendee
+}
+
+// CalendarAttendee is the organizer or an attendee of an event.
+type CalendarAttendee struct {
+	Name    string
+	Address string // From the "mailto:" URI, empty for other URIs.
+	Status  string // Participation status, e.g. NEEDS-ACTION, ACCEPTED, TENTATIVE, DECLINED. Empty for the organizer.
+	Role    string // E.g. REQ-PARTICIPANT, OPT-PARTICIPANT, CHAIR.
+}
+
+func calendarAttendee(p ical.Property) CalendarAttendee {
+	a := CalendarAttendee{
+		Name:   p.Param("CN"),
+		Status: strings.ToUpper(p.Param("PARTSTAT")),
+		Role:   strings.ToUpper(p.Param("ROLE")),
+	}
+	if len(p.Value) > len("mailto:") && strings.EqualFold(p.Value[:len("mailto:")], "mailto:") {
+		a.Address = p.Value[len("mailto:"):]
+	}
+	return a
+}
+
+// calendarEvent returns the first event of a calendar.
+func calendarEvent(cal *ical.Component) (*ical.Component, error) {
+	for i := range cal.Components {
+		if cal.Components[i].Name == "VEVENT" {
+			return &cal.Components[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no event in calendar")
+}
+
+// calendarInvite parses text/calendar part p at path, returning the event and the
+// parsed calendar.
+func calendarInvite(p *message.Part, path []int) (*CalendarInvite, *ical.Component, error) {
+	cal, err := ical.Parse(&moxio.LimitReader{R: p.ReaderUTF8OrBinary(), Limit: 1024 * 1024})
+	if err != nil {
+		return nil, nil, fmt.Errorf("parsing calendar: %w", err)
+	}
+	ev, err := calendarEvent(cal)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	inv := &CalendarInvite{
+		Path:        slices.Clone(path),
+		Method:      strings.ToUpper(cal.Text("METHOD")),
+		UID:         ev.Text("UID"),
+		Summary:     ev.Text("SUMMARY"),
+		Location:    ev.Text("LOCATION"),
+		Description: ev.Text("DESCRIPTION"),
+	}
+	if s := ev.Text("SEQUENCE"); s != "" {
+		inv.Sequence, _ = strconv.Atoi(s)
+	}
+	if dp := ev.Property("DTSTART"); dp == nil {
+		return nil, nil, fmt.Errorf("event without start")
+	} else if inv.Start, inv.AllDay, err = dp.Time(); err != nil {
+		return nil, nil, fmt.Errorf("parsing start of event: %w", err)
+	}
+	if dp := ev.Property("DTEND"); dp != nil {
+		if end, _, err := dp.Time(); err == nil {
+			inv.End = &end
+		}
+	}
+	if op := ev.Property("ORGANIZER"); op != nil {
+		inv.Organizer = calendarAttendee(*op)
+		inv.Organizer.Status = ""
+	}
+	for _, ap := range ev.Properties {
+		if ap.Name == "ATTENDEE" {
+			inv.Attendees = append(inv.Attendees, calendarAttendee(ap))
+		}
+	}
+	return inv, cal, nil
+}
+
+// Properties of an event copied into a reply.
+var calendarReplyProperties = []string{"UID", "SEQUENCE", "RECURRENCE-ID", "DTSTART", "DTEND", "DURATION", "SUMMARY", "ORGANIZER"}
+
+// calendarReply returns an iTIP REPLY for the event in cal, with status (e.g.
+// ACCEPTED) for attendee address.
+func calendarReply(cal *ical.Component, address, status string, now time.Time) ([]byte, error) {
+	ev, err := calendarEvent(cal)
+	if err != nil {
+		return nil, err
+	}
+	rev := ical.Component{Name: "VEVENT"}
+	for _, name := range calendarReplyProperties {
+		if p := ev.Property(name); p != nil {
+			rev.Properties = append(rev.Properties, *p)
+		}
+	}
+	rev.Properties = append(rev.Properties, ical.Property{Name: "DTSTAMP", Value: now.UTC().Format("20060102T150405Z")})
+
+	// Keep the parameters of the attendee from the request, e.g. the name.
+	attendee := ical.Property{Name: "ATTENDEE", Params: map[string][]string{}, Value: "mailto:" + address}
+	for _, ap := range ev.Properties {
+		if ap.Name == "ATTENDEE" && strings.EqualFold(calendarAttendee(ap).Address, address) {
+			for k, v := range ap.Params {
+				attendee.Params[k] = v
+			}
+			attendee.Value = ap.Value
+			break
+		}
+	}
+	delete(attendee.Params, "RSVP")
+	attendee.Params["PARTSTAT"] = []string{status}
+	rev.Properties = append(rev.Properties, attendee)
+
+	reply := ical.Component{
+		Name: "VCALENDAR",
+		Properties: []ical.Property{
+			{Name: "PRODID", Value: "-//mox//webmail " + moxvar.Version + "//EN"},
+			{Name: "VERSION", Value: "2.0"},
+			{Name: "METHOD", Value: "REPLY"},
+		},
+		Components: []ical.Component{rev},
+	}
+	return reply.Marshal(), nil
+}
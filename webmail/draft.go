@@ -0,0 +1,98 @@
+package webmail
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mjl-/bstore"
+	"github.com/mjl-/sherpa"
+
+	"github.com/mjl-/mox/store"
+)
+
+// Drafts not saved for this long are removed, e.g. after the browser was closed
+// while composing.
+const draftMaxAge = 30 * 24 * time.Hour
+
+// Draft is the autosaved state of a message being composed.
+type Draft struct {
+	ID int64
+
+	// Incremented by the server with each save. Saving a draft with another version
+	// than the current version fails with error code "user:draftConflict": the draft
+	// was changed from another device or browser window.
+	Version int64
+
+	Updated time.Time // Set by the server.
+
+	// Compose state. DraftMessageID is the draft message in the Drafts mailbox this
+	// compose started from, if any. DraftID is ignored.
+	Message ComposeMessage
+
+	// Uploaded attachments. Set by the server, ignored when saving.
+	Attachments []DraftAttachment
+}
+
+// DraftAttachment is an attachment uploaded for a draft. It is added to the
+// message when it is sent or saved to the Drafts mailbox.
+type DraftAttachment struct {
+	ID          int64
+	Filename    string
+	ContentType string
+	Size        int64 // Decoded size.
+}
+
+// xdraftConflict aborts the API call with an error indicating the draft was
+// changed elsewhere.
+func xdraftConflict(d store.Draft) {
+	panic(&sherpa.Error{Code: "user:draftConflict", Message: fmt.Sprintf("draft was changed elsewhere, now at version %d", d.Version)})
+}
+
+// xdraftGet returns the draft, aborting with a user error if it doesn't exist.
+func xdraftGet(ctx context.Context, tx *bstore.Tx, draftID int64) store.Draft {
+	d := store.Draft{ID: draftID}
+	err := tx.Get(&d)
+	if err == bstore.ErrAbsent {
+		xcheckuserf(ctx, errors.New("unknown draft"), "get draft")
+	}
+	xcheckf(ctx, err, "get draft")
+	return d
+}
+
+// xdraftAttachments returns the attachments of a draft, including data.
+func xdraftAttachments(ctx context.Context, tx *bstore.Tx, draftID int64) []store.DraftAttachment {
+	l, err := bstore.QueryTx[store.DraftAttachment](tx).FilterNonzero(store.DraftAttachment{DraftID: draftID}).SortAsc("ID").List()
+	xcheckf(ctx, err, "listing draft attachments")
+	return l
+}
+
+// xdraft returns the API form of a stored draft.
+func xdraft(ctx context.Context, tx *bstore.Tx, sd store.Draft) Draft {
+	d := Draft{ID: sd.ID, Version: sd.Version, Updated: sd.Updated}
+	err := json.Unmarshal([]byte(sd.Compose), &d.Message)
+	xcheckf(ctx, err, "parsing compose state of draft")
+	for _, a := range xdraftAttachments(ctx, tx, sd.ID) {
+		d.Attachments = append(d.Attachments, DraftAttachment{a.ID, a.Filename, a.ContentType, int64(len(a.Data))})
+	}
+	return d
+}
+
+// xdraftRemove removes a draft and its attachments.
+func xdraftRemove(ctx context.Context, tx *bstore.Tx, draftID int64) {
+	_, err := bstore.QueryTx[store.DraftAttachment](tx).FilterNonzero(store.DraftAttachment{DraftID: draftID}).Delete()
+	xcheckf(ctx, err, "removing draft attachments")
+	err = tx.Delete(&store.Draft{ID: draftID})
+	xcheckf(ctx, err, "removing draft")
+}
+
+// xdraftsCleanup removes drafts that have not been saved for draftMaxAge.
+func xdraftsCleanup(ctx context.Context, tx *bstore.Tx) {
+	l, err := bstore.QueryTx[store.Draft](tx).FilterLess("Updated", time.Now().Add(-draftMaxAge)).List()
+	xcheckf(ctx, err, "listing old drafts")
+	for _, d := range l {
+		xdraftRemove(ctx, tx, d.ID)
+	}
+}
@@ -31,11 +31,17 @@ type eventWriter struct {
 	accountName  string
 	sessionToken store.SessionToken
 
+	// Modseq of the account up to which the client has all changes, written as SSE
+	// "id" field with the next event if it changed. Clients pass it back when
+	// reconnecting to resume.
+	eventID, sentEventID store.ModSeq
+
 	wrote  bool // To be reset by user, set on write.
 	events chan struct {
-		name string    // E.g. "start" for EventStart.
-		v    any       // Written as JSON.
-		when time.Time // For delaying.
+		name string       // E.g. "start" for EventStart.
+		v    any          // Written as JSON.
+		when time.Time    // For delaying.
+		id   store.ModSeq // If > 0, written as "id".
 	} // Will only be set when waitMin or waitMax is > 0. Closed on connection shutdown.
 	errors chan error // If we have an events channel, we read errors and abort for them.
 }
@@ -56,9 +62,14 @@ func (ew *eventWriter) close() {
 }
 
 // Write an event to the connection, e.g. "start" with value v, written as
-// JSON. This directly writes the event, no more delay.
-func (ew *eventWriter) write(name string, v any) error {
+// JSON, with id if > 0. This directly writes the event, no more delay.
+func (ew *eventWriter) write(name string, v any, id store.ModSeq) error {
 	bw := bufio.NewWriter(ew.out)
+	if id > 0 {
+		if _, err := fmt.Fprintf(bw, "id: %d\n", id); err != nil {
+			return err
+		}
+	}
 	if _, err := fmt.Fprintf(bw, "event: %s\ndata: ", name); err != nil {
 		return err
 	} else if err := json.NewEncoder(bw).Encode(v); err != nil {
@@ -87,6 +98,7 @@ func (ew *eventWriter) xsendEvent(ctx context.Context, log mlog.Log, name string
 			name string
 			v    any
 			when time.Time
+			id   store.ModSeq
 		}, 100)
 		ew.errors = make(chan error)
 		go func() {
@@ -113,7 +125,7 @@ func (ew *eventWriter) xsendEvent(ctx context.Context, log mlog.Log, name string
 					ew.Unlock()
 					return
 				}
-				err := ew.write(ev.name, ev.v)
+				err := ew.write(ev.name, ev.v, ev.id)
 				ew.Unlock()
 				if err != nil {
 					ew.errors <- err
@@ -131,6 +143,11 @@ func (ew *eventWriter) xsendEvent(ctx context.Context, log mlog.Log, name string
 			break
 		}
 	}
+	var id store.ModSeq
+	if ew.eventID != ew.sentEventID {
+		id = ew.eventID
+		ew.sentEventID = id
+	}
 	// If we have an events channel, we have a goroutine that write the events, delayed.
 	if ew.events != nil {
 		wait := ew.waitMin + time.Duration(mathrand2.IntN(1000))*(ew.waitMax-ew.waitMin)/1000
@@ -139,9 +156,10 @@ func (ew *eventWriter) xsendEvent(ctx context.Context, log mlog.Log, name string
 			name string
 			v    any
 			when time.Time
-		}{name, v, when}
+			id   store.ModSeq
+		}{name, v, when, id}
 	} else {
-		err := ew.write(name, v)
+		err := ew.write(name, v, id)
 		if err != nil {
 			panic(ioErr{err})
 		}
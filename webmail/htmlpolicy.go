@@ -0,0 +1,205 @@
+package webmail
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+
+	"github.com/mjl-/mox/store"
+)
+
+// Elements and attributes allowed in composed HTML if the settings don't specify
+// any. Enough for formatted text with links, lists, tables and inline images.
+var (
+	defaultHTMLAllowedTags = []string{
+		"a", "b", "blockquote", "br", "code", "div", "em", "font", "h1", "h2", "h3", "h4", "h5", "h6", "hr", "i", "img", "li", "ol", "p", "pre", "s", "small", "span", "strong", "sub", "sup", "table", "tbody", "td", "tfoot", "th", "thead", "tr", "u", "ul",
+	}
+	defaultHTMLAllowedAttributes = []string{
+		"align", "alt", "color", "colspan", "dir", "height", "href", "rowspan", "src", "style", "title", "width",
+	}
+)
+
+// Elements removed including their content when not allowed. Other elements that
+// are not allowed are replaced by their content.
+var htmlDropContent = map[string]bool{
+	"script":   true,
+	"style":    true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"applet":   true,
+	"frame":    true,
+	"frameset": true,
+	"noscript": true,
+	"template": true,
+	"title":    true,
+	"svg":      true,
+	"math":     true,
+}
+
+// Attributes with a URL as value. Only URLs with a safe scheme are kept.
+var htmlURLAttributes = map[string]bool{
+	"href":       true,
+	"src":        true,
+	"action":     true,
+	"background": true,
+	"cite":       true,
+	"poster":     true,
+	"longdesc":   true,
+}
+
+// htmlPolicy is a policy for sanitizing HTML, with the allowed elements and
+// attributes.
+type htmlPolicy struct {
+	tags  map[string]bool
+	attrs map[string]bool
+}
+
+// newHTMLPolicy returns a policy for the allowed tags and attributes in the
+// settings. Empty lists are replaced by the defaults.
+func newHTMLPolicy(settings store.Settings) *htmlPolicy {
+	tags := settings.HTMLAllowedTags
+	if len(tags) == 0 {
+		tags = defaultHTMLAllowedTags
+	}
+	attrs := settings.HTMLAllowedAttributes
+	if len(attrs) == 0 {
+		attrs = defaultHTMLAllowedAttributes
+	}
+	p := &htmlPolicy{map[string]bool{}, map[string]bool{}}
+	for _, t := range tags {
+		p.tags[strings.ToLower(t)] = true
+	}
+	for _, a := range attrs {
+		p.attrs[strings.ToLower(a)] = true
+	}
+	return p
+}
+
+// displayHTMLPolicy returns the policy for displaying HTML messages, or nil if the
+// settings don't configure allowed tags or attributes.
+func displayHTMLPolicy(settings store.Settings) *htmlPolicy {
+	if len(settings.HTMLAllowedTags) == 0 && len(settings.HTMLAllowedAttributes) == 0 {
+		return nil
+	}
+	return newHTMLPolicy(settings)
+}
+
+// Elements that cannot be allowed in the settings.
+var htmlNeverAllowed = map[string]bool{
+	"script":   true,
+	"iframe":   true,
+	"object":   true,
+	"embed":    true,
+	"applet":   true,
+	"frame":    true,
+	"frameset": true,
+	"base":     true,
+	"meta":     true,
+}
+
+// checkHTMLPolicyNames checks the names of allowed tags or attributes in settings.
+func checkHTMLPolicyNames(l []string, tags bool) error {
+	for _, s := range l {
+		if s == "" || strings.IndexFunc(s, func(c rune) bool {
+			return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-')
+		}) >= 0 {
+			return fmt.Errorf("invalid name %q", s)
+		}
+		if tags && htmlNeverAllowed[strings.ToLower(s)] {
+			return fmt.Errorf("element %q cannot be allowed", s)
+		} else if !tags && strings.HasPrefix(strings.ToLower(s), "on") {
+			return fmt.Errorf("event handler attribute %q cannot be allowed", s)
+		}
+	}
+	return nil
+}
+
+// safeURL returns whether the URL in the value of attribute key can be kept.
+// Relative URLs, and URLs with schemes http, https and mailto are allowed. For
+// "src", "cid" and "data" URIs with images are allowed too.
+func safeURL(key, s string) bool {
+	s = strings.TrimSpace(s)
+	if key == "src" && (caselessPrefix(s, "cid:") || caselessPrefix(s, "data:image/")) {
+		return true
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "", "http", "https", "mailto":
+		return true
+	}
+	return false
+}
+
+// sanitize removes elements and attributes from node and its children that are
+// not allowed by the policy, and comments. The html, head and body elements are
+// always kept.
+func (p *htmlPolicy) sanitize(node *html.Node) {
+	if node.Type == html.ElementNode {
+		attrs := node.Attr[:0]
+		for _, a := range node.Attr {
+			key := strings.ToLower(a.Key)
+			if a.Namespace != "" || !p.attrs[key] || strings.HasPrefix(key, "on") || htmlURLAttributes[key] && !safeURL(key, a.Val) {
+				continue
+			}
+			attrs = append(attrs, a)
+		}
+		node.Attr = attrs
+	}
+
+	parent := node
+	node = node.FirstChild
+	for node != nil {
+		// Set next now, we may remove cur, which clears its NextSibling.
+		cur := node
+		node = node.NextSibling
+
+		switch cur.Type {
+		case html.CommentNode:
+			parent.RemoveChild(cur)
+			continue
+		case html.ElementNode:
+			switch cur.Data {
+			case "html", "head", "body":
+			default:
+				if htmlDropContent[cur.Data] && !p.tags[cur.Data] {
+					parent.RemoveChild(cur)
+					continue
+				} else if !p.tags[cur.Data] {
+					// Replace element with its children, which we still have to sanitize.
+					if cur.FirstChild != nil {
+						node = cur.FirstChild
+					}
+					for c := cur.FirstChild; c != nil; {
+						next := c.NextSibling
+						cur.RemoveChild(c)
+						parent.InsertBefore(c, cur)
+						c = next
+					}
+					parent.RemoveChild(cur)
+					continue
+				}
+			}
+		}
+		p.sanitize(cur)
+	}
+}
+
+// sanitizeComposedHTML returns the composed HTML sanitized by the policy.
+func sanitizeComposedHTML(p *htmlPolicy, s string) (string, error) {
+	node, err := html.Parse(strings.NewReader(s))
+	if err != nil {
+		return "", fmt.Errorf("parsing html: %v", err)
+	}
+	p.sanitize(node)
+	var sb strings.Builder
+	if err := html.Render(&sb, node); err != nil {
+		return "", fmt.Errorf("writing html: %v", err)
+	}
+	return sb.String(), nil
+}
@@ -93,11 +93,12 @@ func messageItem(log mlog.Log, m store.Message, state *msgState, moreHeaders []s
 	if err != nil {
 		return MessageItem{}, fmt.Errorf("parsing message %d for item: %v", m.ID, err)
 	}
+	auth := parseAuthResults(log, m.MsgPrefix, m.Auth)
 	// Clear largish unused data.
 	m.MsgPrefix = nil
 	m.ParsedBuf = nil
 	l := messageItemMoreHeaders(moreHeaders, pm)
-	return MessageItem{m, pm.envelope, pm.attachments, pm.isSigned, pm.isEncrypted, true, l}, nil
+	return MessageItem{m, pm.envelope, pm.attachments, pm.isSigned, pm.isEncrypted, pm.smime, pm.calendar, pm.unsubscribe, auth, true, l, nil}, nil
 }
 
 func parsedMessage(log mlog.Log, m *store.Message, state *msgState, full, msgitem, msgitemHeaders bool) (pm ParsedMessage, rerr error) {
@@ -170,6 +171,20 @@ func parsedMessage(log mlog.Log, m *store.Message, state *msgState, full, msgite
 		pm.Headers = map[string][]string{}
 	}
 
+	if (full || msgitem) && state.part.BodyOffset > 0 {
+		hdrs := textproto.MIMEHeader(pm.Headers)
+		if !full && !msgitemHeaders {
+			var err error
+			hdrs, err = state.part.Header()
+			if err != nil {
+				log.Debugx("parsing headers for list-unsubscribe", err, slog.Int64("msgid", m.ID))
+			}
+		}
+		if hdrs != nil {
+			pm.unsubscribe = parseListUnsubscribe(hdrs.Get("List-Unsubscribe"), hdrs.Get("List-Unsubscribe-Post"), len(m.DKIMDomains) > 0)
+		}
+	}
+
 	pm.Texts = []string{}
 
 	// We track attachments from multipart/mixed differently from other attachments.
@@ -241,6 +256,14 @@ func parsedMessage(log mlog.Log, m *store.Message, state *msgState, full, msgite
 			if parent == nil && mt == "MULTIPART/ENCRYPTED" {
 				pm.isEncrypted = true
 			}
+			// Invitations are shown with the message, the part is still listed as attachment.
+			if mt == "TEXT/CALENDAR" && (full || msgitem) && pm.calendar == nil {
+				if inv, _, err := calendarInvite(&p, path); err != nil {
+					log.Debugx("parsing calendar part", err, slog.Int64("msgid", m.ID))
+				} else {
+					pm.calendar = inv
+				}
+			}
 			// todo: possibly do not include anything below multipart/alternative that starts with text/html, they may be cids. perhaps have a separate list of attachments for the text vs html version?
 			if p.MediaType != "MULTIPART" {
 				var parentct string
@@ -296,6 +319,16 @@ func parsedMessage(log mlog.Log, m *store.Message, state *msgState, full, msgite
 	}
 	usePart(*state.part, -1, nil, []int{}, false)
 
+	// S/MIME signatures are verified when parsing, the result is small enough to
+	// include in message items.
+	if (full || msgitem) && smimeSigned(state.part) {
+		status, cert := smimeVerify(state.part, state.msgr, pm.envelope.From, m.Received)
+		pm.smime = &status
+		if full {
+			pm.SMIMECertificate = cert
+		}
+	}
+
 	pm.attachments = []Attachment{}
 	pm.attachments = append(pm.attachments, attachmentsMixed...)
 	pm.attachments = append(pm.attachments, attachmentsOther...)
@@ -0,0 +1,275 @@
+package webmail
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	pgperrors "golang.org/x/crypto/openpgp/errors"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+	"github.com/mjl-/mox/wkd"
+)
+
+// PGPResult is the result of verifying and/or decrypting an OpenPGP message.
+type PGPResult struct {
+	Decrypted bool // Whether the message was encrypted, and has been decrypted.
+	Signed    bool // Whether the message has a signature, possibly inside the encrypted message.
+
+	SignatureValid  bool
+	SignatureError  string   // If signature could not be verified, e.g. for an unknown key or bad signature.
+	SignerKeyID     string   // Upper case hex, from the signature.
+	SignerAddresses []string // Addresses of the signing key, if the key is known.
+	SignerFrom      bool     // Whether the signing key has the From address of the message.
+	SignerKeySource string   // "account" for a key stored in the account, "wkd" for a key from the Web Key Directory of the From address.
+
+	Texts []string // Text parts of the decrypted message.
+}
+
+// Maximum size of a decrypted message, protecting against compression bombs.
+const pgpMaxDecryptedSize = 100 * 1024 * 1024
+
+var pgpConfig = &packet.Config{DefaultHash: crypto.SHA256}
+
+// pgpKeyRing returns the keys of the account as a key ring, for verifying and
+// decrypting messages. Private keys are decrypted with passphrase. Private keys
+// that are encrypted and cannot be decrypted with passphrase are included
+// encrypted, causing a passphrase error if they are needed for decrypting.
+func pgpKeyRing(log mlog.Log, keys []store.PGPKey, passphrase string) openpgp.EntityList {
+	var l openpgp.EntityList
+	for _, k := range keys {
+		e, err := k.Entity(passphrase)
+		if err != nil && errors.Is(err, store.ErrPGPPassphrase) {
+			var el openpgp.EntityList
+			el, err = openpgp.ReadArmoredKeyRing(strings.NewReader(k.PrivateKey))
+			if err == nil {
+				e = el[0]
+			}
+		}
+		if err != nil {
+			log.Errorx("parsing pgp key of account", err, slog.String("fingerprint", k.Fingerprint))
+			continue
+		}
+		l = append(l, e)
+	}
+	return l
+}
+
+// pgpAddressKeys returns the public keys from keys that have address addr.
+func pgpAddressKeys(log mlog.Log, keys []store.PGPKey, addr smtp.Address) openpgp.EntityList {
+	var l openpgp.EntityList
+	for _, k := range keys {
+		e, err := store.PGPKey{PublicKey: k.PublicKey}.Entity("")
+		if err != nil {
+			log.Errorx("parsing pgp key of account", err, slog.String("fingerprint", k.Fingerprint))
+			continue
+		}
+		if wkd.EntityHasAddress(e, addr) {
+			l = append(l, e)
+		}
+	}
+	return l
+}
+
+// pgpCRLF returns buf with lines ending in CRLF instead of LF.
+func pgpCRLF(buf []byte) []byte {
+	buf = bytes.ReplaceAll(buf, []byte("\r\n"), []byte("\n"))
+	return bytes.ReplaceAll(buf, []byte("\n"), []byte("\r\n"))
+}
+
+// pgpSign returns an armored detached signature over data by signer.
+func pgpSign(signer *openpgp.Entity, data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&b, signer, bytes.NewReader(data), pgpConfig); err != nil {
+		return nil, err
+	}
+	b.WriteString("\n")
+	return pgpCRLF(b.Bytes()), nil
+}
+
+// pgpEncrypt returns data as armored message encrypted to the keys in to, and
+// signed by signer if not nil.
+func pgpEncrypt(to openpgp.EntityList, signer *openpgp.Entity, data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	aw, err := armor.Encode(&b, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	w, err := openpgp.Encrypt(aw, to, signer, nil, pgpConfig)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	if err := aw.Close(); err != nil {
+		return nil, err
+	}
+	b.WriteString("\n")
+	return pgpCRLF(b.Bytes()), nil
+}
+
+// pgpDecrypt decrypts the armored message in r. If the decrypted message is signed,
+// the signature is verified and the details are in md.
+func pgpDecrypt(ring openpgp.EntityList, r io.Reader) (data []byte, md *openpgp.MessageDetails, rerr error) {
+	block, err := armor.Decode(r)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading armored message: %v", err)
+	}
+	prompt := func(keys []openpgp.Key, symmetric bool) ([]byte, error) {
+		// Keys in the ring are already decrypted if possible.
+		return nil, store.ErrPGPPassphrase
+	}
+	md, err = openpgp.ReadMessage(block.Body, ring, prompt, pgpConfig)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err = io.ReadAll(&moxio.LimitReader{R: md.UnverifiedBody, Limit: pgpMaxDecryptedSize})
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading decrypted message: %v", err)
+	}
+	return data, md, nil
+}
+
+// pgpSignatureKeyID returns the ID of the key that made the armored signature.
+func pgpSignatureKeyID(r io.Reader) (uint64, error) {
+	block, err := armor.Decode(r)
+	if err != nil {
+		return 0, err
+	}
+	p, err := packet.Read(block.Body)
+	if err != nil {
+		return 0, err
+	}
+	switch sig := p.(type) {
+	case *packet.Signature:
+		if sig.IssuerKeyId != nil {
+			return *sig.IssuerKeyId, nil
+		}
+	case *packet.SignatureV3:
+		return sig.IssuerKeyId, nil
+	}
+	return 0, errors.New("no signature with key id")
+}
+
+// pgpVerifier verifies signatures with the keys of the account, and falls back
+// to keys in the Web Key Directory of the From address.
+type pgpVerifier struct {
+	ctx      context.Context
+	log      mlog.Log
+	ring     openpgp.EntityList
+	from     smtp.Address
+	wkdKeys  openpgp.EntityList
+	wkdTried bool
+}
+
+// wkdLookup looks up keys for the From address, once.
+func (v *pgpVerifier) wkdLookup() openpgp.EntityList {
+	if !v.wkdTried && !v.from.IsZero() {
+		v.wkdTried = true
+		var err error
+		v.wkdKeys, err = wkd.Lookup(v.ctx, v.log.Logger, v.from)
+		v.log.Debugx("looking up pgp key in web key directory", err, slog.Any("address", v.from))
+	}
+	return v.wkdKeys
+}
+
+// result fills the signer fields of r after a signature was checked.
+func (v *pgpVerifier) result(r *PGPResult, keyID uint64, signer *openpgp.Entity, err error) {
+	r.Signed = true
+	r.SignerKeyID = fmt.Sprintf("%016X", keyID)
+	if signer != nil {
+		r.SignerKeySource = "wkd"
+		if slices.ContainsFunc(v.ring, func(e *openpgp.Entity) bool { return e.PrimaryKey.Fingerprint == signer.PrimaryKey.Fingerprint }) {
+			r.SignerKeySource = "account"
+		}
+		for _, a := range wkd.EntityAddresses(signer) {
+			r.SignerAddresses = append(r.SignerAddresses, a.String())
+		}
+		r.SignerFrom = wkd.EntityHasAddress(signer, v.from)
+	}
+	if err == nil {
+		r.SignatureValid = true
+	} else if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		r.SignatureError = "unknown key, add the public key of the sender to your account to verify the signature"
+	} else {
+		r.SignatureError = err.Error()
+	}
+}
+
+// verifyDetached checks the armored detached signature of signed, with a
+// multipart/signed message.
+func (v *pgpVerifier) verifyDetached(r *PGPResult, signed []byte, sig []byte) {
+	keyID, err := pgpSignatureKeyID(bytes.NewReader(sig))
+	if err != nil {
+		r.Signed = true
+		r.SignatureError = fmt.Sprintf("reading signature: %v", err)
+		return
+	}
+	signer, err := openpgp.CheckArmoredDetachedSignature(v.ring, bytes.NewReader(signed), bytes.NewReader(sig))
+	if errors.Is(err, pgperrors.ErrUnknownIssuer) {
+		if l := v.wkdLookup(); len(l) > 0 {
+			signer, err = openpgp.CheckArmoredDetachedSignature(l, bytes.NewReader(signed), bytes.NewReader(sig))
+		}
+	}
+	v.result(r, keyID, signer, err)
+}
+
+// pgpSignedParts returns the signed data and the signature of a multipart/signed
+// part with OpenPGP signature. Offsets of the parts are in r.
+func pgpSignedParts(p *message.Part, r io.ReaderAt) (signed, sig []byte, rerr error) {
+	if p.MediaType != "MULTIPART" || p.MediaSubType != "SIGNED" || !strings.EqualFold(p.ContentTypeParams["protocol"], "application/pgp-signature") {
+		return nil, nil, errors.New("not an openpgp signed message")
+	} else if len(p.Parts) != 2 {
+		return nil, nil, fmt.Errorf("signed message with %d parts, need 2", len(p.Parts))
+	}
+	sp := p.Parts[0]
+	signed = make([]byte, sp.EndOffset-sp.HeaderOffset)
+	if _, err := r.ReadAt(signed, sp.HeaderOffset); err != nil {
+		return nil, nil, fmt.Errorf("reading signed part: %v", err)
+	}
+	sig, err := io.ReadAll(&moxio.LimitReader{R: p.Parts[1].Reader(), Limit: 1024 * 1024})
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading signature: %v", err)
+	}
+	return signed, sig, nil
+}
+
+// pgpTexts returns the contents of the text/plain parts of p.
+func pgpTexts(p *message.Part) ([]string, error) {
+	var l []string
+	if p.MediaType == "MULTIPART" {
+		for i := range p.Parts {
+			tl, err := pgpTexts(&p.Parts[i])
+			if err != nil {
+				return nil, err
+			}
+			l = append(l, tl...)
+		}
+		return l, nil
+	}
+	if (p.MediaType == "" || p.MediaType == "TEXT") && (p.MediaSubType == "" || p.MediaSubType == "PLAIN") {
+		buf, err := io.ReadAll(&moxio.LimitReader{R: p.ReaderUTF8OrBinary(), Limit: 2 * 1024 * 1024})
+		if err != nil {
+			return nil, fmt.Errorf("reading text part: %v", err)
+		}
+		l = append(l, string(buf))
+	}
+	return l, nil
+}
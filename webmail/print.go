@@ -0,0 +1,280 @@
+package webmail
+
+// Rendering of a single message for printing or archiving, as self-contained HTML
+// document or as PDF. The HTML document has the message headers and either the
+// text or the sanitized HTML part, with "cid:" images inlined as data: URIs.
+// External resources are never loaded.
+//
+// The PDF has the headers and text of the message, without images or HTML
+// formatting, there is no HTML renderer. It uses a standard PDF font, so
+// characters outside the windows-1252 character set are replaced.
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+	"golang.org/x/text/encoding/charmap"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+)
+
+// printHeader is a header shown above the message in printed form.
+type printHeader struct {
+	Key   string
+	Value string
+}
+
+// printHeaders returns the headers to show for a printed message.
+func printHeaders(env MessageEnvelope) []printHeader {
+	addrs := func(l []MessageAddress) string {
+		var r []string
+		for _, a := range l {
+			s := a.User + "@" + a.Domain.Name()
+			if a.Name != "" {
+				s = a.Name + " <" + s + ">"
+			}
+			r = append(r, s)
+		}
+		return strings.Join(r, ", ")
+	}
+
+	l := []printHeader{
+		{"From", addrs(env.From)},
+		{"To", addrs(env.To)},
+	}
+	if len(env.CC) > 0 {
+		l = append(l, printHeader{"Cc", addrs(env.CC)})
+	}
+	if !env.Date.IsZero() {
+		l = append(l, printHeader{"Date", env.Date.Format(time.RFC1123Z)})
+	}
+	l = append(l, printHeader{"Subject", env.Subject})
+	return l
+}
+
+var printTemplate = template.Must(template.New("print").Parse(`<!doctype html>
+<html>
+	<head>
+		<meta charset="utf-8" />
+		<title>{{ .Subject }}</title>
+		<style>
+body { font-family: sans-serif; margin: 1em; }
+.headers { border-collapse: collapse; margin-bottom: 1em; }
+.headers td { padding: .1em .5em .1em 0; vertical-align: top; }
+.headers td:first-child { font-weight: bold; white-space: nowrap; }
+.text { white-space: pre-wrap; font-family: monospace; }
+@media print { body { margin: 0; } }
+		</style>
+		{{- range .Styles }}
+		{{ . }}
+		{{- end }}
+	</head>
+	<body>
+		<table class="headers">
+			{{- range .Headers }}
+			<tr><td>{{ .Key }}:</td><td>{{ .Value }}</td></tr>
+			{{- end }}
+		</table>
+		<hr />
+		{{- if .HTML }}
+		<div>{{ .HTML }}</div>
+		{{- else }}
+		{{- range .Texts }}
+		<div class="text">{{ . }}</div>
+		{{- end }}
+		{{- end }}
+	</body>
+</html>
+`))
+
+// printHTML writes a self-contained HTML document for the message. If htmlPart is
+// set, its sanitized content is included, with inlined "cid:" images. Otherwise
+// the texts are included.
+func printHTML(log mlog.Log, w io.Writer, env MessageEnvelope, texts []string, htmlPart *message.Part, parents []*message.Part, policy *htmlPolicy) error {
+	type data struct {
+		Subject string
+		Headers []printHeader
+		Styles  []template.HTML
+		Texts   []string
+		HTML    template.HTML
+	}
+	d := data{Subject: env.Subject, Headers: printHeaders(env), Texts: texts}
+
+	if htmlPart != nil {
+		node, err := html.Parse(htmlPart.ReaderUTF8OrBinary())
+		if err != nil {
+			return fmt.Errorf("parsing html: %v", err)
+		}
+		var totalSize int64
+		if err := inlineNode(htmlPart, parents, node, &totalSize); err != nil {
+			return fmt.Errorf("inline cid uris in html nodes: %w", err)
+		}
+		if policy != nil {
+			policy.sanitize(node)
+		}
+		sanitizeNode(node)
+
+		// We keep the style elements from the message head, and the contents of its body.
+		var buf bytes.Buffer
+		var walk func(n *html.Node) error
+		walk = func(n *html.Node) error {
+			if n.Type == html.ElementNode && n.Data == "style" {
+				var sb strings.Builder
+				if err := html.Render(&sb, n); err != nil {
+					return err
+				}
+				d.Styles = append(d.Styles, template.HTML(sb.String()))
+				return nil
+			} else if n.Type == html.ElementNode && n.Data == "body" {
+				for c := n.FirstChild; c != nil; c = c.NextSibling {
+					if err := html.Render(&buf, c); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if err := walk(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(node); err != nil {
+			return fmt.Errorf("rendering html: %v", err)
+		}
+		d.HTML = template.HTML(buf.String())
+	}
+
+	err := printTemplate.Execute(w, d)
+	log.Check(err, "writing print html")
+	return nil
+}
+
+// Page layout for PDFs, A4 in points, with monospace Courier font so we can wrap
+// lines without font metrics.
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfFontSize   = 10
+	pdfLineHeight = 12
+	pdfLineChars  = (pdfPageWidth - 2*pdfMargin) * 10 / (6 * pdfFontSize) // Courier glyphs are 0.6 of the font size wide.
+	pdfPageLines  = (pdfPageHeight - 2*pdfMargin) / pdfLineHeight
+)
+
+// pdfWrap splits text into lines of at most pdfLineChars characters, breaking at
+// spaces where possible.
+func pdfWrap(text string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		line = strings.ReplaceAll(line, "\t", "        ")
+		for utf8.RuneCountInString(line) > pdfLineChars {
+			r := []rune(line)
+			n := pdfLineChars
+			if i := strings.LastIndex(string(r[:n]), " "); i > 0 {
+				n = utf8.RuneCountInString(string(r[:n])[:i]) + 1
+			}
+			lines = append(lines, strings.TrimRight(string(r[:n]), " "))
+			line = string(r[n:])
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// pdfString returns s as PDF literal string in windows-1252 encoding, as used by
+// the standard fonts with WinAnsiEncoding.
+func pdfString(s string) string {
+	enc := charmap.Windows1252.NewEncoder()
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, c := range s {
+		buf, err := enc.Bytes([]byte(string(c)))
+		if err != nil || len(buf) != 1 || buf[0] < 0x20 {
+			buf = []byte{'?'}
+		}
+		switch buf[0] {
+		case '(', ')', '\\':
+			b.WriteByte('\\')
+			b.WriteByte(buf[0])
+		default:
+			if buf[0] >= 0x80 {
+				fmt.Fprintf(&b, "\\%03o", buf[0])
+			} else {
+				b.WriteByte(buf[0])
+			}
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// printPDF writes a PDF document with the headers and texts of the message.
+func printPDF(w io.Writer, env MessageEnvelope, texts []string) error {
+	var lines []string
+	for _, h := range printHeaders(env) {
+		lines = append(lines, pdfWrap(h.Key+": "+h.Value)...)
+	}
+	lines = append(lines, "")
+	for i, text := range texts {
+		if i > 0 {
+			lines = append(lines, "", strings.Repeat("-", pdfLineChars), "")
+		}
+		lines = append(lines, pdfWrap(text)...)
+	}
+
+	var pages [][]string
+	for len(lines) > 0 {
+		n := min(len(lines), pdfPageLines)
+		pages = append(pages, lines[:n])
+		lines = lines[n:]
+	}
+
+	// Objects: 1 catalog, 2 pages, 3 font, then a page and content stream for each page.
+	var objs []string
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 4+2*i))
+	}
+	objs = append(objs,
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+	)
+	for i, page := range pages {
+		var content strings.Builder
+		fmt.Fprintf(&content, "BT\n/F1 %d Tf\n%d TL\n%d %d Td\n", pdfFontSize, pdfLineHeight, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+		for _, line := range page {
+			fmt.Fprintf(&content, "%s '\n", pdfString(line))
+		}
+		content.WriteString("ET\n")
+		objs = append(objs,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 5+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+		)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objs))
+	for i, obj := range objs {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
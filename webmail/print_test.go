@@ -0,0 +1,34 @@
+package webmail
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/dns"
+)
+
+func TestPrint(t *testing.T) {
+	tcompare(t, pdfWrap("a\r\nb\tc"), []string{"a", "b        c"})
+	long := strings.Repeat("word ", 30)
+	lines := pdfWrap(long)
+	tcompare(t, len(lines), 2)
+	tcompare(t, len(lines[0]) <= pdfLineChars, true)
+	tcompare(t, strings.HasSuffix(lines[0], "word"), true)
+	tcompare(t, pdfWrap(strings.Repeat("x", pdfLineChars+1)), []string{strings.Repeat("x", pdfLineChars), "x"})
+
+	tcompare(t, pdfString(`a(b)\c`), `(a\(b\)\\c)`)
+	tcompare(t, pdfString("é€☺"), `(\351\200?)`)
+
+	env := MessageEnvelope{
+		Subject: "hi",
+		From:    []MessageAddress{{Name: "Mox", User: "mox", Domain: dns.Domain{ASCII: "mox.example"}}},
+	}
+	var buf bytes.Buffer
+	err := printPDF(&buf, env, []string{strings.Repeat("line\n", 2*pdfPageLines)})
+	tcheck(t, err, "pdf")
+	pdf := buf.String()
+	tcompare(t, strings.Contains(pdf, "/Count 3"), true)
+	tcompare(t, strings.Contains(pdf, "(From: Mox <mox@mox.example>) '"), true)
+	tcompare(t, strings.HasSuffix(pdf, "%%EOF\n"), true)
+}
@@ -0,0 +1,351 @@
+package webmail
+
+// Proxy for remote content in HTML messages. When enabled in the account
+// settings, URLs of images in HTML messages shown with external resources are
+// rewritten to point to the proxy endpoint, which fetches them. Senders don't
+// learn the IP address and user-agent of the user, and tracking parameters are
+// removed from the URLs.
+//
+// HTML messages are displayed in a sandboxed iframe, so the browser doesn't send
+// the session cookie with requests from the message. The proxy URLs are signed
+// instead, with a key that is kept in memory for the lifetime of the process. The
+// signature includes the login session the message was rendered for, and the
+// proxy only fetches while that session is valid, so URLs stop working after
+// logging out.
+
+import (
+	"context"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/html"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/store"
+)
+
+const (
+	proxyMaxSize      = 10 * 1024 * 1024 // Per resource.
+	proxyCacheMaxSize = 64 * 1024 * 1024 // For all cached resources.
+	proxyCacheTTL     = time.Hour
+	proxyURLValidity  = 24 * time.Hour
+)
+
+// Key for signing proxy URLs.
+var proxyKey = func() []byte {
+	buf := make([]byte, 32)
+	if _, err := cryptorand.Read(buf); err != nil {
+		panic(fmt.Sprintf("reading random proxy key: %v", err))
+	}
+	return buf
+}()
+
+// Query string parameters used for tracking, removed from proxied URLs. Parameters
+// starting with one of proxyTrackerPrefixes are removed as well.
+var proxyTrackerParams = map[string]bool{
+	"fbclid":      true,
+	"gclid":       true,
+	"dclid":       true,
+	"msclkid":     true,
+	"yclid":       true,
+	"igshid":      true,
+	"mkt_tok":     true,
+	"oly_anon_id": true,
+	"oly_enc_id":  true,
+	"vero_id":     true,
+	"vero_conv":   true,
+	"__s":         true,
+}
+
+var proxyTrackerPrefixes = []string{"utm_", "mc_", "_hs"}
+
+// proxyStripTrackers removes tracking parameters from the query string of u.
+func proxyStripTrackers(u *url.URL) {
+	if u.RawQuery == "" {
+		return
+	}
+	q := u.Query()
+	var changed bool
+	for k := range q {
+		lk := strings.ToLower(k)
+		strip := proxyTrackerParams[lk]
+		for _, pre := range proxyTrackerPrefixes {
+			strip = strip || strings.HasPrefix(lk, pre)
+		}
+		if strip {
+			delete(q, k)
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = q.Encode()
+	}
+}
+
+// proxySign returns the signature for the values, which must start with the kind
+// of URL, e.g. "proxy".
+func proxySign(values ...string) string {
+	mac := hmac.New(sha256.New, proxyKey)
+	mac.Write([]byte(strings.Join(values, "\n")))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// proxySession is the login session proxy URLs are signed for.
+type proxySession struct {
+	accountName string
+	sessionID   int64
+}
+
+// sign returns the signature for a proxy URL for resource u.
+func (ps proxySession) sign(u, expires string) string {
+	return proxySign("proxy", ps.accountName, fmt.Sprintf("%d", ps.sessionID), u, expires)
+}
+
+// proxyURL returns the URL for fetching resource s through the proxy, relative to
+// the message HTML endpoints (msg/<id>/htmlexternal). Relative URLs in s are
+// resolved against base, if not nil. An empty string is returned for URLs that
+// cannot be proxied.
+func (ps proxySession) proxyURL(base *url.URL, s string, now time.Time) string {
+	u, err := url.Parse(strings.TrimSpace(s))
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return ""
+	}
+	u.Fragment = ""
+	u.User = nil
+	proxyStripTrackers(u)
+	us := u.String()
+	expires := fmt.Sprintf("%d", now.Add(proxyURLValidity).Unix())
+	v := url.Values{"a": {ps.accountName}, "i": {fmt.Sprintf("%d", ps.sessionID)}, "u": {us}, "e": {expires}, "s": {ps.sign(us, expires)}}
+	return "../../proxy?" + v.Encode()
+}
+
+// proxyNode rewrites URLs for images and other media in node and its descendants
+// to go through the proxy. URLs that cannot be proxied are removed, they would be
+// blocked by the CSP anyway. The href of a base element is used for resolving
+// relative URLs and then removed, it would break the relative proxy URLs.
+func (ps proxySession) proxyNode(node *html.Node, now time.Time) {
+	var base *url.URL
+	var findBase func(n *html.Node) bool
+	findBase = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "base" {
+			for i, a := range n.Attr {
+				if a.Key == "href" && a.Namespace == "" {
+					base, _ = url.Parse(strings.TrimSpace(a.Val))
+					n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+					return true
+				}
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if findBase(c) {
+				return true
+			}
+		}
+		return false
+	}
+	findBase(node)
+
+	var rewrite func(n *html.Node)
+	rewrite = func(n *html.Node) {
+		i := 0
+		for i < len(n.Attr) {
+			a := n.Attr[i]
+			var nv string
+			switch {
+			case a.Namespace != "":
+			case a.Key == "src" || a.Key == "background" || a.Key == "poster":
+				if caselessPrefix(a.Val, "data:") {
+					break
+				}
+				if nv = ps.proxyURL(base, a.Val, now); nv == "" {
+					n.Attr = append(n.Attr[:i], n.Attr[i+1:]...)
+					continue
+				}
+				n.Attr[i].Val = nv
+			case a.Key == "srcset":
+				// Comma-separated URLs, each optionally followed by a descriptor.
+				var l []string
+				for _, c := range strings.Split(a.Val, ",") {
+					t := strings.Fields(c)
+					if len(t) == 0 {
+						continue
+					}
+					if pu := ps.proxyURL(base, t[0], now); pu != "" {
+						l = append(l, strings.Join(append([]string{pu}, t[1:]...), " "))
+					}
+				}
+				n.Attr[i].Val = strings.Join(l, ", ")
+			}
+			i++
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			rewrite(c)
+		}
+	}
+	rewrite(node)
+}
+
+// proxyClient is used for fetching remote resources for the proxy, BIMI logos and
+// one-click unsubscribes. URLs come from messages, so only public addresses are
+// allowed, the proxy must not be used to reach internal services.
+var proxyClient = mox.PublicHTTPClient(30*time.Second, "http", "https")
+
+type proxyEntry struct {
+	contentType string
+	data        []byte
+	expires     time.Time
+}
+
+// Cache of fetched resources, shared by all accounts.
+var proxyCache = struct {
+	sync.Mutex
+	entries map[string]proxyEntry
+	size    int
+}{entries: map[string]proxyEntry{}}
+
+func proxyCacheGet(u string, now time.Time) (proxyEntry, bool) {
+	proxyCache.Lock()
+	defer proxyCache.Unlock()
+	e, ok := proxyCache.entries[u]
+	if ok && now.After(e.expires) {
+		delete(proxyCache.entries, u)
+		proxyCache.size -= len(e.data)
+		return proxyEntry{}, false
+	}
+	return e, ok
+}
+
+func proxyCacheAdd(u string, e proxyEntry, now time.Time) {
+	proxyCache.Lock()
+	defer proxyCache.Unlock()
+	if oe, ok := proxyCache.entries[u]; ok {
+		proxyCache.size -= len(oe.data)
+	}
+	proxyCache.entries[u] = e
+	proxyCache.size += len(e.data)
+	if proxyCache.size <= proxyCacheMaxSize {
+		return
+	}
+	// Remove expired entries first, then others until we are within the limit.
+	for k, oe := range proxyCache.entries {
+		if now.After(oe.expires) {
+			delete(proxyCache.entries, k)
+			proxyCache.size -= len(oe.data)
+		}
+	}
+	for k, oe := range proxyCache.entries {
+		if proxyCache.size <= proxyCacheMaxSize {
+			break
+		}
+		if k != u {
+			delete(proxyCache.entries, k)
+			proxyCache.size -= len(oe.data)
+		}
+	}
+}
+
+// proxyFetch fetches the image at u.
+func proxyFetch(ctx context.Context, u string) (proxyEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return proxyEntry{}, err
+	}
+	// Generic headers, not revealing details of the user's browser.
+	req.Header.Set("User-Agent", "mox")
+	req.Header.Set("Accept", "image/*")
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		return proxyEntry{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return proxyEntry{}, fmt.Errorf("remote responded with status %d", resp.StatusCode)
+	}
+	// SVG images can contain scripts, we only serve raster images.
+	ct, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || !strings.HasPrefix(ct, "image/") || ct == "image/svg+xml" {
+		return proxyEntry{}, fmt.Errorf("remote responded with unsupported content-type %q", resp.Header.Get("Content-Type"))
+	}
+	if resp.ContentLength > proxyMaxSize {
+		return proxyEntry{}, fmt.Errorf("remote resource too large, %d bytes", resp.ContentLength)
+	}
+	data, err := io.ReadAll(&moxio.LimitReader{R: resp.Body, Limit: proxyMaxSize})
+	if err != nil {
+		return proxyEntry{}, fmt.Errorf("reading remote resource: %w", err)
+	}
+	return proxyEntry{contentType: ct, data: data}, nil
+}
+
+// serveProxy serves a request for a remote resource, with a URL signed by
+// proxyURL for a login session that is still valid.
+func serveProxy(ctx context.Context, log mlog.Log, w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	ps := proxySession{accountName: q.Get("a")}
+	u, expires, sig := q.Get("u"), q.Get("e"), q.Get("s")
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err == nil {
+		ps.sessionID, err = strconv.ParseInt(q.Get("i"), 10, 64)
+	}
+	if err != nil || !hmac.Equal([]byte(sig), []byte(ps.sign(u, expires))) {
+		http.Error(w, "403 - forbidden - bad signature", http.StatusForbidden)
+		return
+	}
+	now := time.Now()
+	if now.Unix() > exp {
+		http.Error(w, "403 - forbidden - proxy url expired", http.StatusForbidden)
+		return
+	}
+	if _, err := store.SessionGetID(ctx, log, ps.accountName, ps.sessionID); err != nil {
+		http.Error(w, "403 - forbidden - session ended", http.StatusForbidden)
+		return
+	}
+
+	e, ok := proxyCacheGet(u, now)
+	result := "cached"
+	if !ok {
+		e, err = proxyFetch(ctx, u)
+		if err != nil {
+			log.Debugx("fetching remote resource for proxy", err, slog.String("url", u))
+			metricProxy.WithLabelValues("error").Inc()
+			http.Error(w, "502 - bad gateway - fetching remote resource failed", http.StatusBadGateway)
+			return
+		}
+		e.expires = now.Add(proxyCacheTTL)
+		proxyCacheAdd(u, e, now)
+		result = "fetched"
+	}
+	metricProxy.WithLabelValues(result).Inc()
+
+	h := w.Header()
+	h.Set("Content-Type", e.contentType)
+	h.Set("Content-Length", fmt.Sprintf("%d", len(e.data)))
+	h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(proxyCacheTTL.Seconds())))
+	h.Set("Content-Security-Policy", "sandbox; default-src 'none'")
+	h.Set("X-Content-Type-Options", "nosniff")
+	h.Set("Referrer-Policy", "no-referrer")
+	if r.Method == "HEAD" {
+		return
+	}
+	_, err = w.Write(e.data)
+	log.Check(err, "writing proxied resource")
+}
@@ -0,0 +1,357 @@
+package webmail
+
+// Preparing replies and forwards: the addressees, threading headers, subject and
+// quoted body are determined on the server, so clients don't have to implement
+// the rules, and attachments of the original message can be included when sending
+// without going through the client.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"unicode/utf16"
+
+	xhtml "golang.org/x/net/html"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// ResponseMode is how to respond to a message.
+type ResponseMode string
+
+const (
+	ResponseReply     ResponseMode = "reply"     // To the sender, or Reply-To.
+	ResponseReplyAll  ResponseMode = "replyall"  // To the sender and all other recipients.
+	ResponseReplyList ResponseMode = "replylist" // To the mailing list, from List-Post header.
+	ResponseForward   ResponseMode = "forward"
+)
+
+// ResponseCompose is a prepared reply or forward of a message, for starting a
+// compose window.
+type ResponseCompose struct {
+	From       string // Identity or account address the message was sent to, if any.
+	To         []string
+	Cc         []string
+	Bcc        []string
+	ReplyTo    string   // Reply-To of the identity, if any.
+	InReplyTo  string   // Message-ID of the message, for the In-Reply-To header.
+	References []string // For the References header.
+	Subject    string
+
+	// Text with signature and quoted message, or forwarded headers and message. The
+	// cursor should be placed at EditOffset, in UTF-16 code units as used by
+	// JavaScript.
+	TextBody   string
+	EditOffset int
+
+	// Quoted or forwarded message as HTML fragment, with the sanitized HTML part of
+	// the message if it has one, for composing HTML messages.
+	HTMLBody string
+
+	// For forwards, all attachments of the message, to include when sending.
+	ForwardAttachments ForwardAttachments
+}
+
+// accountAddress returns whether a is an address of the account: a destination,
+// an address at a catchall domain, or an alias the account can send as.
+func accountAddress(accConf config.Account, a MessageAddress) bool {
+	for dest := range accConf.Destinations {
+		if strings.HasPrefix(dest, "@") {
+			if strings.EqualFold(dest[1:], a.Domain.ASCII) {
+				return true
+			}
+			continue
+		}
+		addr, err := smtp.ParseAddress(dest)
+		if err == nil && addr.Localpart.String() == a.User && addr.Domain == a.Domain {
+			return true
+		}
+	}
+	for _, aa := range accConf.Aliases {
+		if aa.Alias.AllowMsgFrom && aa.Alias.LocalpartStr == a.User && aa.Alias.Domain == a.Domain {
+			return true
+		}
+	}
+	return false
+}
+
+// responseIdentity returns the first identity with an address in l.
+func responseIdentity(accConf config.Account, l []MessageAddress) *config.Identity {
+	for _, a := range l {
+		for i, ident := range accConf.Identities {
+			addr, err := smtp.ParseAddress(ident.Address)
+			if err == nil && addr.Localpart.String() == a.User && addr.Domain == a.Domain {
+				return &accConf.Identities[i]
+			}
+		}
+	}
+	return nil
+}
+
+// displayName returns the name for use in an address, quoted if needed.
+func displayName(name string) string {
+	if strings.ContainsAny(name, `()<>[]:;@\,."`) {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(name, `\`, `\\`), `"`, `\"`) + `"`
+	}
+	return name
+}
+
+// formatMessageAddress returns the address with name for use in compose fields.
+func formatMessageAddress(a MessageAddress) string {
+	s := "<" + a.User + "@" + a.Domain.Name() + ">"
+	if a.Name != "" {
+		s = displayName(a.Name) + " " + s
+	}
+	return s
+}
+
+// responseSubject returns the subject with prefix "Re:" or "Fwd:", unless already
+// present.
+func responseSubject(prefix, subject string) string {
+	if len(subject) >= len(prefix) && strings.EqualFold(subject[:len(prefix)], prefix) {
+		return subject
+	}
+	return prefix + " " + subject
+}
+
+// responseRecipients returns the addressees for a reply, leaving out addresses of
+// the account.
+func responseRecipients(accConf config.Account, env MessageEnvelope, all bool) (to, cc, bcc []MessageAddress) {
+	contains := func(l []MessageAddress, a MessageAddress) bool {
+		for _, e := range l {
+			if (e.User == "" || a.User == "" || e.User == a.User) && e.Domain == a.Domain {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(env.From) == 1 && accountAddress(accConf, env.From[0]) {
+		// Replying to our own message, copy the original recipients.
+		to = append(to, env.To...)
+	} else {
+		if len(env.ReplyTo) > 0 {
+			to = append(to, env.ReplyTo...)
+		} else {
+			to = append(to, env.From...)
+		}
+		if all {
+			for _, a := range env.To {
+				if !contains(to, a) && !accountAddress(accConf, a) {
+					to = append(to, a)
+				}
+			}
+		}
+	}
+	if all {
+		for _, a := range env.CC {
+			if !accountAddress(accConf, a) && !contains(to, a) && !contains(cc, a) {
+				cc = append(cc, a)
+			}
+		}
+		for _, a := range env.BCC {
+			if !accountAddress(accConf, a) {
+				bcc = append(bcc, a)
+			}
+		}
+	}
+	return
+}
+
+// responseQuotedHTML returns the body of the HTML part of a message, sanitized and
+// with "cid:" images inlined, for including in a response.
+func responseQuotedHTML(htmlPart *message.Part, parents []*message.Part, policy *htmlPolicy) (string, error) {
+	node, err := xhtml.Parse(htmlPart.ReaderUTF8OrBinary())
+	if err != nil {
+		return "", fmt.Errorf("parsing html: %v", err)
+	}
+	var totalSize int64
+	if err := inlineNode(htmlPart, parents, node, &totalSize); err != nil {
+		return "", fmt.Errorf("inline cid uris in html nodes: %w", err)
+	}
+	if policy != nil {
+		policy.sanitize(node)
+	}
+	sanitizeNode(node)
+
+	// Style elements are left out, they would apply to the entire response.
+	var sb strings.Builder
+	var walk func(n *xhtml.Node) error
+	walk = func(n *xhtml.Node) error {
+		if n.Type == xhtml.ElementNode && n.Data == "body" {
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				if c.Type == xhtml.ElementNode && c.Data == "style" {
+					continue
+				}
+				if err := xhtml.Render(&sb, c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := walk(c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk(node); err != nil {
+		return "", fmt.Errorf("rendering html: %v", err)
+	}
+	return sb.String(), nil
+}
+
+// xresponseCompose prepares a response to the message.
+func xresponseCompose(ctx context.Context, accConf config.Account, settings store.Settings, pm ParsedMessage, p *message.Part, mode ResponseMode, selection string) ResponseCompose {
+	env := pm.envelope
+	var rc ResponseCompose
+
+	var to, cc, bcc []MessageAddress
+	switch mode {
+	case ResponseReply, ResponseReplyAll:
+		to, cc, bcc = responseRecipients(accConf, env, mode == ResponseReplyAll)
+	case ResponseReplyList:
+		if pm.ListReplyAddress == nil {
+			xcheckuserf(ctx, errors.New("message has no mailing list address"), "replying to list")
+		}
+		to = []MessageAddress{*pm.ListReplyAddress}
+	case ResponseForward:
+	default:
+		xcheckuserf(ctx, fmt.Errorf("unknown response mode %q", mode), "checking response mode")
+	}
+	for _, a := range to {
+		rc.To = append(rc.To, formatMessageAddress(a))
+	}
+	for _, a := range cc {
+		rc.Cc = append(rc.Cc, formatMessageAddress(a))
+	}
+	for _, a := range bcc {
+		rc.Bcc = append(rc.Bcc, formatMessageAddress(a))
+	}
+
+	ident := responseIdentity(accConf, env.To)
+	if ident != nil {
+		rc.From = "<" + ident.Address + ">"
+		if ident.DisplayName != "" {
+			rc.From = displayName(ident.DisplayName) + " " + rc.From
+		}
+		rc.ReplyTo = ident.ReplyTo
+	} else {
+		for _, a := range append(append([]MessageAddress{}, env.To...), env.CC...) {
+			if accountAddress(accConf, a) {
+				rc.From = formatMessageAddress(MessageAddress{Name: accConf.FullName, User: a.User, Domain: a.Domain})
+				break
+			}
+		}
+	}
+
+	if mode != ResponseForward && env.MessageID != "" {
+		rc.InReplyTo = env.MessageID
+		h, err := p.Header()
+		xcheckf(ctx, err, "parsing header")
+		rc.References = h.Values("References")
+		if len(rc.References) == 0 && env.InReplyTo != "" {
+			rc.References = []string{env.InReplyTo}
+		}
+		rc.References = append(rc.References, env.MessageID)
+	}
+
+	if mode == ResponseForward {
+		rc.Subject = responseSubject("Fwd:", env.Subject)
+	} else {
+		rc.Subject = responseSubject("Re:", env.Subject)
+	}
+
+	text := selection
+	if text == "" && len(pm.Texts) > 0 {
+		text = pm.Texts[0]
+	}
+	text = strings.ReplaceAll(text, "\r", "")
+	for strings.Contains(text, "\n\n\n") {
+		text = strings.ReplaceAll(text, "\n\n\n", "\n\n")
+	}
+	text = strings.TrimSpace(text)
+
+	// The HTML part is only quoted when responding to the entire message.
+	quotedHTML := "<div style=\"white-space: pre-wrap\">" + html.EscapeString(text) + "</div>"
+	if selection == "" && pm.HasHTML {
+		htmlPart := p
+		var parents []*message.Part
+		for _, index := range pm.HTMLPath {
+			if index < 0 || index >= len(htmlPart.Parts) {
+				xcheckf(ctx, errors.New("invalid html part path"), "looking up html part")
+			}
+			parents = append(parents, htmlPart)
+			htmlPart = &htmlPart.Parts[index]
+		}
+		s, err := responseQuotedHTML(htmlPart, parents, displayHTMLPolicy(settings))
+		xcheckf(ctx, err, "quoting html part")
+		quotedHTML = s
+	}
+
+	if mode == ResponseForward {
+		prefix := "\n\n---- Forwarded Message ----\n"
+		var htmlPrefix string
+		for _, k := range []string{"Subject", "Date", "From", "Reply-To", "To", "Cc"} {
+			for _, v := range pm.Headers[k] {
+				prefix += fmt.Sprintf("%-10s%s\n", k+":", v)
+				htmlPrefix += "<br>" + html.EscapeString(k+": "+v)
+			}
+		}
+		rc.TextBody = prefix + "\n" + text
+		rc.HTMLBody = "<p>---- Forwarded Message ----" + htmlPrefix + "</p>\n" + quotedHTML
+		for _, a := range pm.attachments {
+			rc.ForwardAttachments.MessageID = pm.ID
+			rc.ForwardAttachments.Paths = append(rc.ForwardAttachments.Paths, a.Path)
+		}
+		return rc
+	}
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		lines = append(lines, "> "+line)
+	}
+	quoted := strings.Join(lines, "\n")
+	var sig string
+	if ident != nil {
+		sig = strings.Join(ident.Signature, "\n")
+	} else {
+		sig = settings.Signature
+	}
+	quoting := settings.Quoting
+	if ident != nil && ident.ReplyQuoting != "" {
+		quoting = store.Quoting(ident.ReplyQuoting)
+	}
+
+	var onWrote string
+	if !env.Date.IsZero() && len(env.From) == 1 {
+		from := env.From[0]
+		name := from.Name
+		if name == "" {
+			name = from.User + "@" + from.Domain.Name()
+		}
+		onWrote = "On " + env.Date.Format("Mon, 2 Jan 2006 at 15:04") + ", " + name + " wrote:"
+	}
+	if quoting == store.Default && selection != "" || quoting == store.Bottom {
+		rc.TextBody = quoted + "\n\n"
+		rc.EditOffset = len(utf16.Encode([]rune(rc.TextBody)))
+		rc.TextBody += "\n\n" + sig
+	} else {
+		rc.TextBody = "\n\n" + sig + "\n"
+		if onWrote != "" {
+			rc.TextBody += onWrote + "\n"
+		}
+		rc.TextBody += quoted
+	}
+	rc.HTMLBody = "<blockquote type=\"cite\">" + quotedHTML + "</blockquote>\n"
+	if onWrote != "" {
+		rc.HTMLBody = "<p>" + html.EscapeString(onWrote) + "</p>\n" + rc.HTMLBody
+	}
+	return rc
+}
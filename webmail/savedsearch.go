@@ -0,0 +1,70 @@
+package webmail
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+// SavedSearch is a named search, shown as virtual folder in the mailbox list.
+type SavedSearch struct {
+	ID        int64
+	Name      string
+	Search    string // As entered in the search bar, for opening the search.
+	Filter    Filter
+	NotFilter NotFilter
+
+	// Number of matching messages, and how many of them are unseen. Set by the
+	// server, ignored when saving.
+	Total  int
+	Unseen int
+}
+
+// EventSavedSearches has the saved searches of the account with up to date
+// message counts. Sent after changes to messages that modify the counts.
+type EventSavedSearches struct {
+	SavedSearches []SavedSearch
+}
+
+// savedSearchFilters is stored JSON-encoded in store.SavedSearch.Filters.
+type savedSearchFilters struct {
+	Filter    Filter
+	NotFilter NotFilter
+}
+
+// savedSearchesList returns the saved searches of the account, sorted by name,
+// with message counts.
+func savedSearchesList(ctx context.Context, log mlog.Log, acc *store.Account, tx *bstore.Tx, rejectsMailbox string) ([]SavedSearch, error) {
+	l := []SavedSearch{}
+	err := bstore.QueryTx[store.SavedSearch](tx).SortAsc("Name").ForEach(func(xss store.SavedSearch) error {
+		var ssf savedSearchFilters
+		if err := json.Unmarshal([]byte(xss.Filters), &ssf); err != nil {
+			return fmt.Errorf("parsing filters for saved search %d: %v", xss.ID, err)
+		}
+		ss := SavedSearch{ID: xss.ID, Name: xss.Name, Search: xss.Search, Filter: ssf.Filter, NotFilter: ssf.NotFilter}
+		if err := savedSearchCount(ctx, log, acc, tx, rejectsMailbox, &ss); err != nil {
+			return err
+		}
+		l = append(l, ss)
+		return nil
+	})
+	return l, err
+}
+
+// savedSearchCount sets the message counts of ss.
+func savedSearchCount(ctx context.Context, log mlog.Log, acc *store.Account, tx *bstore.Tx, rejectsMailbox string, ss *SavedSearch) error {
+	ss.Total = 0
+	ss.Unseen = 0
+	return matchingMessages(ctx, log, acc, tx, rejectsMailbox, Query{Filter: ss.Filter, NotFilter: ss.NotFilter}, func(m store.Message) error {
+		ss.Total++
+		if !m.Seen {
+			ss.Unseen++
+		}
+		return nil
+	})
+}
@@ -0,0 +1,204 @@
+package webmail
+
+// Heuristics for recognizing messages impersonating known senders, for showing
+// a warning in the message view. Messages can be from any address, the checks
+// look for signs of an attempt to mislead the reader.
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/publicsuffix"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// SenderWarningKind is the heuristic that triggered a sender warning.
+type SenderWarningKind string
+
+const (
+	// Display name of From matches a contact, but the address is not one of the
+	// contact's addresses.
+	SenderWarningDisplayName SenderWarningKind = "displayname"
+	// Domain of From looks like, but is not, a domain of the account or a known
+	// sender: differs by a character or two, or looks the same when rendered.
+	SenderWarningLookalike SenderWarningKind = "lookalike"
+	// Replies go to an unknown address in another organization than the From
+	// address.
+	SenderWarningReplyTo SenderWarningKind = "replyto"
+)
+
+// SenderWarning is a reason to be suspicious of the sender of a message.
+type SenderWarning struct {
+	Kind    SenderWarningKind
+	Similar string // Known address or domain the sender resembles, or the Reply-To address.
+	Text    string // Human-readable explanation.
+}
+
+// knownSenders holds the addresses and names the account knows, from contacts and
+// from recipients of sent messages, to compare senders of messages against.
+type knownSenders struct {
+	accConf   config.Account
+	names     map[string][]string // Lower-case contact name to its lower-case addresses.
+	addresses map[string]bool     // Lower-case addresses, with unicode domain.
+	domains   map[string]bool     // Lower-case unicode domains, including those of the account.
+}
+
+// ensureKnownSenders loads the known senders, if not already loaded.
+func ensureKnownSenders(tx *bstore.Tx, acc *store.Account, ks *knownSenders) (*knownSenders, error) {
+	if ks != nil {
+		return ks, nil
+	}
+
+	accConf, _ := acc.Conf()
+	ks = &knownSenders{accConf, map[string][]string{}, map[string]bool{}, map[string]bool{}}
+	for dest := range accConf.Destinations {
+		if strings.HasPrefix(dest, "@") {
+			if d, err := dns.ParseDomain(dest[1:]); err == nil {
+				ks.domains[strings.ToLower(d.Name())] = true
+			}
+		} else if addr, err := smtp.ParseAddress(dest); err == nil {
+			ks.domains[strings.ToLower(addr.Domain.Name())] = true
+		}
+	}
+
+	err := bstore.QueryTx[store.Contact](tx).ForEach(func(c store.Contact) error {
+		name := strings.ToLower(strings.TrimSpace(c.FullName))
+		for _, email := range c.Emails {
+			i := strings.LastIndex(email, "@")
+			if i < 0 {
+				continue
+			}
+			ks.addresses[email] = true
+			ks.domains[email[i+1:]] = true
+			// Collected contacts without name have the address as name.
+			if name != "" && name != email {
+				ks.names[name] = append(ks.names[name], email)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing contacts: %v", err)
+	}
+
+	err = bstore.QueryTx[store.Recipient](tx).ForEach(func(r store.Recipient) error {
+		ks.addresses[strings.ToLower(r.Localpart+"@"+r.Domain)] = true
+		ks.domains[strings.ToLower(r.Domain)] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing recipients: %v", err)
+	}
+	return ks, nil
+}
+
+// addressKey returns the lower-case address with unicode domain, as used in
+// knownSenders.
+func addressKey(a MessageAddress) string {
+	return strings.ToLower(a.User + "@" + a.Domain.Name())
+}
+
+// senderWarnings returns the reasons to be suspicious of the sender of the
+// message, if any.
+func senderWarnings(log mlog.Log, ks *knownSenders, mi MessageItem) []SenderWarning {
+	env := mi.Envelope
+	if ks == nil || len(env.From) != 1 || accountAddress(ks.accConf, env.From[0]) {
+		return nil
+	}
+	from := env.From[0]
+	fromAddr := addressKey(from)
+	fromDomain := strings.ToLower(from.Domain.Name())
+	var l []SenderWarning
+
+	// A display name of a contact, or a display name that is itself an address, with
+	// a different address than the actual.
+	name := strings.ToLower(strings.Trim(strings.TrimSpace(from.Name), `"'`))
+	if emails, ok := ks.names[name]; ok && !ks.addresses[fromAddr] {
+		l = append(l, SenderWarning{SenderWarningDisplayName, emails[0], fmt.Sprintf("The sender has the name of contact %s, but a different address.", emails[0])})
+	} else if strings.Contains(name, "@") && strings.Trim(name, "<>") != fromAddr {
+		l = append(l, SenderWarning{SenderWarningDisplayName, name, fmt.Sprintf("The sender name looks like address %s, but the message is from another address.", name)})
+	}
+
+	if !ks.domains[fromDomain] {
+		fromSkel := domainSkeleton(fromDomain)
+		for d := range ks.domains {
+			if domainSkeleton(d) == fromSkel {
+				l = append(l, SenderWarning{SenderWarningLookalike, d, fmt.Sprintf("The sender domain %s looks the same as known domain %s, but is different.", fromDomain, d)})
+				break
+			}
+			// Short domains are often legitimately similar, and are not checked.
+			n := len(d)
+			if n < 5 || n-len(fromDomain) > 2 || len(fromDomain)-n > 2 {
+				continue
+			}
+			if dist := levenshtein(fromDomain, d); dist <= 1 || dist <= 2 && n >= 10 {
+				l = append(l, SenderWarning{SenderWarningLookalike, d, fmt.Sprintf("The sender domain %s is similar to known domain %s.", fromDomain, d)})
+				break
+			}
+		}
+	}
+
+	// Mailing lists commonly set Reply-To to the list address.
+	if mi.Unsubscribe == nil {
+		for _, rt := range env.ReplyTo {
+			rtAddr := addressKey(rt)
+			if ks.addresses[rtAddr] || rt.Domain == from.Domain {
+				continue
+			}
+			fromOrg := publicsuffix.Lookup(context.TODO(), log.Logger, from.Domain)
+			rtOrg := publicsuffix.Lookup(context.TODO(), log.Logger, rt.Domain)
+			if fromOrg != rtOrg {
+				l = append(l, SenderWarning{SenderWarningReplyTo, rtAddr, fmt.Sprintf("Replies will go to %s, an unknown address at another domain than the sender.", rtAddr)})
+				break
+			}
+		}
+	}
+	return l
+}
+
+// Characters that look (nearly) the same as ASCII characters when rendered, and
+// character sequences that are easily confused.
+var skeletonReplacer = strings.NewReplacer(
+	// Cyrillic.
+	"а", "a", "с", "c", "ԁ", "d", "е", "e", "һ", "h", "і", "i", "ј", "j", "о", "o", "р", "p", "ԛ", "q", "ѕ", "s", "ԝ", "w", "х", "x", "у", "y",
+	// Greek.
+	"α", "a", "ε", "e", "ι", "i", "κ", "k", "ν", "v", "ο", "o", "ρ", "p", "τ", "t", "υ", "u", "χ", "x",
+	// Latin lookalikes.
+	"ı", "i", "ɡ", "g", "ℓ", "l",
+	"0", "o", "1", "l", "rn", "m", "vv", "w", "cl", "d",
+)
+
+// domainSkeleton returns the domain with confusable characters replaced, for
+// comparing how domains look.
+func domainSkeleton(d string) string {
+	return skeletonReplacer.Replace(d)
+}
+
+// levenshtein returns the edit distance between a and b, in characters.
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
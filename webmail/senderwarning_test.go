@@ -0,0 +1,86 @@
+package webmail
+
+import (
+	"testing"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+)
+
+func TestSenderWarnings(t *testing.T) {
+	log := mlog.New("webmail", nil)
+
+	ks := &knownSenders{
+		accConf: config.Account{
+			Destinations: map[string]config.Destination{"mjl@mox.example": {}},
+		},
+		names: map[string][]string{
+			"alice example": {"alice@example.org"},
+		},
+		addresses: map[string]bool{
+			"alice@example.org": true,
+			"bob@bank.example":  true,
+		},
+		domains: map[string]bool{
+			"mox.example":  true,
+			"example.org":  true,
+			"bank.example": true,
+		},
+	}
+
+	addr := func(name, user, domain string) MessageAddress {
+		return MessageAddress{Name: name, User: user, Domain: dns.Domain{ASCII: domain}}
+	}
+	check := func(from, replyTo []MessageAddress, exp []SenderWarningKind) {
+		t.Helper()
+		mi := MessageItem{Envelope: MessageEnvelope{From: from, ReplyTo: replyTo}}
+		var kinds []SenderWarningKind
+		for _, w := range senderWarnings(log, ks, mi) {
+			kinds = append(kinds, w.Kind)
+		}
+		tcompare(t, kinds, exp)
+	}
+
+	// Known senders, and unknown senders at unrelated domains.
+	check([]MessageAddress{addr("Alice Example", "alice", "example.org")}, nil, nil)
+	check([]MessageAddress{addr("Bob", "bob", "bank.example")}, []MessageAddress{addr("", "alice", "example.org")}, nil)
+	check([]MessageAddress{addr("Someone", "someone", "other.example")}, nil, nil)
+	check(nil, nil, nil)
+
+	// Our own messages are not checked.
+	check([]MessageAddress{addr("Alice Example", "mjl", "mox.example")}, nil, nil)
+
+	// Contact name with other address.
+	check([]MessageAddress{addr("Alice Example", "alice", "other.example")}, nil, []SenderWarningKind{SenderWarningDisplayName})
+	check([]MessageAddress{addr(`"alice example"`, "alice", "other.example")}, nil, []SenderWarningKind{SenderWarningDisplayName})
+	// Address as name.
+	check([]MessageAddress{addr("bob@bank.example", "x", "other.example")}, nil, []SenderWarningKind{SenderWarningDisplayName})
+	check([]MessageAddress{addr("x@other.example", "x", "other.example")}, nil, nil)
+
+	// Look-alike domains.
+	check([]MessageAddress{addr("", "bob", "bank.exarnple")}, nil, []SenderWarningKind{SenderWarningLookalike})
+	check([]MessageAddress{addr("", "bob", "examp1e.org")}, nil, []SenderWarningKind{SenderWarningLookalike})
+	check([]MessageAddress{addr("", "bob", "banks.example")}, nil, []SenderWarningKind{SenderWarningLookalike})
+	check([]MessageAddress{{User: "bob", Domain: dns.Domain{ASCII: "xn--bnk-8cd.example", Unicode: "bаnk.example"}}}, nil, []SenderWarningKind{SenderWarningLookalike})
+	check([]MessageAddress{addr("", "mjl", "mox.exampel")}, nil, []SenderWarningKind{SenderWarningLookalike})
+
+	// Reply-To at an unknown address in another organization.
+	check([]MessageAddress{addr("", "bob", "bank.example")}, []MessageAddress{addr("", "support", "other.example")}, []SenderWarningKind{SenderWarningReplyTo})
+	check([]MessageAddress{addr("", "bob", "bank.example")}, []MessageAddress{addr("", "support", "bank.example")}, nil)
+	check([]MessageAddress{addr("", "bob", "mail.bank.example")}, []MessageAddress{addr("", "support", "bank.example")}, nil)
+
+	// Mailing lists commonly set Reply-To.
+	mi := MessageItem{
+		Envelope:    MessageEnvelope{From: []MessageAddress{addr("", "bob", "bank.example")}, ReplyTo: []MessageAddress{addr("", "list", "lists.example")}},
+		Unsubscribe: &ListUnsubscribe{Mailto: "mailto:list-leave@lists.example"},
+	}
+	tcompare(t, len(senderWarnings(log, ks, mi)), 0)
+
+	// Multiple warnings.
+	check([]MessageAddress{addr("Alice Example", "alice", "examp1e.org")}, []MessageAddress{addr("", "x", "other.example")}, []SenderWarningKind{SenderWarningDisplayName, SenderWarningLookalike, SenderWarningReplyTo})
+
+	tcompare(t, levenshtein("", "abc"), 3)
+	tcompare(t, levenshtein("kitten", "sitting"), 3)
+	tcompare(t, levenshtein("bаnk", "bank"), 1)
+}
@@ -0,0 +1,91 @@
+package webmail
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/smime"
+	"github.com/mjl-/mox/smtp"
+)
+
+// SMIMEStatus is the result of verifying the S/MIME signature of a message.
+type SMIMEStatus struct {
+	Valid           bool     // Signature is valid and the certificate is trusted.
+	Error           string   // If not valid, e.g. for a modified message or unknown certificate authority.
+	SignerAddresses []string // Email addresses in the certificate of the signer.
+	SignerFrom      bool     // Whether the certificate is for the From address of the message.
+	Fingerprint     string   // Of the public key of the signer, for pinning.
+}
+
+// SMIMECertificate has details of the certificate that signed a message.
+type SMIMECertificate struct {
+	Subject      string
+	Issuer       string
+	SerialNumber string // Hex.
+	NotBefore    time.Time
+	NotAfter     time.Time
+	Chain        []string // Subjects of verified certificate chain, from signer to root.
+}
+
+// smimeSigned returns whether p is a multipart/signed message with an S/MIME
+// signature.
+func smimeSigned(p *message.Part) bool {
+	if p.MediaType != "MULTIPART" || p.MediaSubType != "SIGNED" || len(p.Parts) != 2 {
+		return false
+	}
+	proto := strings.ToLower(p.ContentTypeParams["protocol"])
+	return proto == "application/pkcs7-signature" || proto == "application/x-pkcs7-signature"
+}
+
+// smimeVerify verifies the S/MIME signature of multipart/signed message p, with
+// the message data in r. The certificate is verified at the time the message was
+// received, against the certificate authorities of the system and from the TLS
+// configuration.
+func smimeVerify(p *message.Part, r io.ReaderAt, from []MessageAddress, received time.Time) (SMIMEStatus, *SMIMECertificate) {
+	sp := p.Parts[0]
+	signed := make([]byte, sp.EndOffset-sp.HeaderOffset)
+	if _, err := r.ReadAt(signed, sp.HeaderOffset); err != nil {
+		return SMIMEStatus{Error: fmt.Sprintf("reading signed part: %v", err)}, nil
+	}
+	sig, err := io.ReadAll(&moxio.LimitReader{R: p.Parts[1].Reader(), Limit: 1024 * 1024})
+	if err != nil {
+		return SMIMEStatus{Error: fmt.Sprintf("reading signature: %v", err)}, nil
+	}
+
+	result, err := smime.Verify(signed, sig, mox.Conf.Static.TLS.CertPool, received)
+	var status SMIMEStatus
+	if err != nil {
+		status.Error = err.Error()
+	} else {
+		status.Valid = true
+	}
+	if result.Signer == nil {
+		return status, nil
+	}
+	status.Fingerprint = smime.Fingerprint(result.Signer)
+	for _, a := range smime.Addresses(result.Signer) {
+		status.SignerAddresses = append(status.SignerAddresses, a.String())
+	}
+	if len(from) == 1 {
+		if lp, err := smtp.ParseLocalpart(from[0].User); err == nil {
+			status.SignerFrom = smime.HasAddress(result.Signer, smtp.NewAddress(lp, from[0].Domain))
+		}
+	}
+
+	cert := &SMIMECertificate{
+		Subject:      result.Signer.Subject.String(),
+		Issuer:       result.Signer.Issuer.String(),
+		SerialNumber: fmt.Sprintf("%x", result.Signer.SerialNumber),
+		NotBefore:    result.Signer.NotBefore,
+		NotAfter:     result.Signer.NotAfter,
+	}
+	for _, c := range result.Chain {
+		cert.Chain = append(cert.Chain, c.Subject.String())
+	}
+	return status, cert
+}
@@ -0,0 +1,36 @@
+"use strict";
+// Javascript is generated from typescript, do not modify generated javascript because changes will be overwritten.
+// Service worker for the webmail. It shows notifications for Web Push messages
+// about new messages, also when no webmail tab is open. The types for service
+// workers are not in the DOM library we compile with.
+const sw = self;
+sw.addEventListener('push', (e) => {
+	let p;
+	try {
+		p = e.data.json();
+	}
+	catch (err) {
+		return;
+	}
+	e.waitUntil(sw.registration.showNotification(p.Title, {
+		body: p.Body,
+		tag: 'message-' + p.MessageID,
+		data: p,
+	}));
+});
+// Focus an open webmail tab, or open a new one, showing the message.
+sw.addEventListener('notificationclick', (e) => {
+	e.notification.close();
+	const p = e.notification.data;
+	const url = sw.registration.scope + '#' + encodeURIComponent(p.Mailbox) + ',' + p.MessageID;
+	e.waitUntil((async () => {
+		const clients = await sw.clients.matchAll({ type: 'window' });
+		for (const c of clients) {
+			if (c.url.startsWith(sw.registration.scope)) {
+				await c.navigate(url);
+				return c.focus();
+			}
+		}
+		return sw.clients.openWindow(url);
+	})());
+});
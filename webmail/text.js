@@ -271,6 +271,15 @@ var api;
 		ViewMode["ModeHTML"] = "html";
 		ViewMode["ModeHTMLExt"] = "htmlext";
 	})(ViewMode = api.ViewMode || (api.ViewMode = {}));
+	// BulkOp is an operation applied to all messages matching a query.
+	let BulkOp;
+	(function (BulkOp) {
+		BulkOp["BulkMarkRead"] = "markread";
+		BulkOp["BulkMarkUnread"] = "markunread";
+		BulkOp["BulkMove"] = "move";
+		BulkOp["BulkLabel"] = "label";
+		BulkOp["BulkDelete"] = "delete";
+	})(BulkOp = api.BulkOp || (api.BulkOp = {}));
 	// SecurityResult indicates whether a security feature is supported.
 	let SecurityResult;
 	(function (SecurityResult) {
@@ -289,39 +298,90 @@ var api;
 		Quoting["Bottom"] = "bottom";
 		Quoting["Top"] = "top";
 	})(Quoting = api.Quoting || (api.Quoting = {}));
-	api.structTypes = { "Address": true, "Attachment": true, "ChangeMailboxAdd": true, "ChangeMailboxCounts": true, "ChangeMailboxKeywords": true, "ChangeMailboxRemove": true, "ChangeMailboxRename": true, "ChangeMailboxSpecialUse": true, "ChangeMsgAdd": true, "ChangeMsgFlags": true, "ChangeMsgRemove": true, "ChangeMsgThread": true, "ComposeMessage": true, "Domain": true, "DomainAddressConfig": true, "Envelope": true, "EventStart": true, "EventViewChanges": true, "EventViewErr": true, "EventViewMsgs": true, "EventViewReset": true, "File": true, "Filter": true, "Flags": true, "ForwardAttachments": true, "FromAddressSettings": true, "Mailbox": true, "Message": true, "MessageAddress": true, "MessageEnvelope": true, "MessageItem": true, "NotFilter": true, "Page": true, "ParsedMessage": true, "Part": true, "Query": true, "RecipientSecurity": true, "Request": true, "Ruleset": true, "Settings": true, "SpecialUse": true, "SubmitMessage": true };
-	api.stringsTypes = { "AttachmentType": true, "CSRFToken": true, "Localpart": true, "Quoting": true, "SecurityResult": true, "ThreadMode": true, "ViewMode": true };
+	// ResponseMode is how to respond to a message.
+	let ResponseMode;
+	(function (ResponseMode) {
+		ResponseMode["ResponseReply"] = "reply";
+		ResponseMode["ResponseReplyAll"] = "replyall";
+		ResponseMode["ResponseReplyList"] = "replylist";
+		ResponseMode["ResponseForward"] = "forward";
+	})(ResponseMode = api.ResponseMode || (api.ResponseMode = {}));
+	// SenderWarningKind is the heuristic that triggered a sender warning.
+	let SenderWarningKind;
+	(function (SenderWarningKind) {
+		SenderWarningKind["SenderWarningDisplayName"] = "displayname";
+		SenderWarningKind["SenderWarningLookalike"] = "lookalike";
+		SenderWarningKind["SenderWarningReplyTo"] = "replyto";
+	})(SenderWarningKind = api.SenderWarningKind || (api.SenderWarningKind = {}));
+	api.structTypes = { "Address": true, "Attachment": true, "AuthCheck": true, "AuthResults": true, "BulkAction": true, "CalendarAttendee": true, "CalendarInvite": true, "ChangeMailboxAdd": true, "ChangeMailboxCounts": true, "ChangeMailboxKeywords": true, "ChangeMailboxRemove": true, "ChangeMailboxRename": true, "ChangeMailboxSpecialUse": true, "ChangeMsgAdd": true, "ChangeMsgFlags": true, "ChangeMsgRemove": true, "ChangeMsgThread": true, "ComposeMessage": true, "ComposeTemplate": true, "DelegatedAccess": true, "Delegation": true, "Domain": true, "DomainAddressConfig": true, "Draft": true, "DraftAttachment": true, "Envelope": true, "EventBulkProgress": true, "EventSavedSearches": true, "EventStart": true, "EventViewChanges": true, "EventViewErr": true, "EventViewMsgs": true, "EventViewReset": true, "File": true, "Filter": true, "Flags": true, "ForwardAttachments": true, "FromAddressSettings": true, "Identity": true, "Impersonation": true, "InlineFile": true, "Label": true, "ListUnsubscribe": true, "Mailbox": true, "Message": true, "MessageAddress": true, "MessageAuth": true, "MessageAuthDKIM": true, "MessageAuthDNSBL": true, "MessageEnvelope": true, "MessageItem": true, "MessageJunkVerdict": true, "MessageJunkWord": true, "NotFilter": true, "PGPResult": true, "Page": true, "ParsedMessage": true, "Part": true, "PasskeyAssertion": true, "PasskeyGetOptions": true, "Query": true, "RecipientSecurity": true, "Request": true, "ResponseCompose": true, "Rule": true, "Ruleset": true, "SMIMECertificate": true, "SMIMEStatus": true, "SavedSearch": true, "SenderWarning": true, "Settings": true, "SharedTemplate": true, "SpecialUse": true, "SubmitMessage": true, "SubmitResult": true, "Template": true, "TemplateAttachment": true, "TemplateRef": true, "ThreadSummary": true, "Unsubscribe": true, "ViewResume": true };
+	api.stringsTypes = { "AttachmentType": true, "BulkOp": true, "CSRFToken": true, "Localpart": true, "Quoting": true, "ResponseMode": true, "SecurityResult": true, "SenderWarningKind": true, "ThreadMode": true, "ViewMode": true };
 	api.intsTypes = { "ModSeq": true, "UID": true, "Validation": true };
 	api.types = {
-		"Request": { "Name": "Request", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Cancel", "Docs": "", "Typewords": ["bool"] }, { "Name": "Query", "Docs": "", "Typewords": ["Query"] }, { "Name": "Page", "Docs": "", "Typewords": ["Page"] }] },
+		"PasskeyGetOptions": { "Name": "PasskeyGetOptions", "Docs": "", "Fields": [{ "Name": "Challenge", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "RPID", "Docs": "", "Typewords": ["string"] }, { "Name": "Timeout", "Docs": "", "Typewords": ["int32"] }] },
+		"PasskeyAssertion": { "Name": "PasskeyAssertion", "Docs": "", "Fields": [{ "Name": "CredentialID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ClientDataJSON", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "AuthenticatorData", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"Request": { "Name": "Request", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Cancel", "Docs": "", "Typewords": ["bool"] }, { "Name": "KeepViews", "Docs": "", "Typewords": ["bool"] }, { "Name": "Query", "Docs": "", "Typewords": ["Query"] }, { "Name": "Page", "Docs": "", "Typewords": ["Page"] }] },
 		"Query": { "Name": "Query", "Docs": "", "Fields": [{ "Name": "OrderAsc", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threading", "Docs": "", "Typewords": ["ThreadMode"] }, { "Name": "Filter", "Docs": "", "Typewords": ["Filter"] }, { "Name": "NotFilter", "Docs": "", "Typewords": ["NotFilter"] }] },
-		"Filter": { "Name": "Filter", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxChildrenIncluded", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Oldest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Newest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Headers", "Docs": "", "Typewords": ["[]", "[]", "string"] }, { "Name": "SizeMin", "Docs": "", "Typewords": ["int64"] }, { "Name": "SizeMax", "Docs": "", "Typewords": ["int64"] }] },
-		"NotFilter": { "Name": "NotFilter", "Docs": "", "Fields": [{ "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Filter": { "Name": "Filter", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxChildrenIncluded", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "WordHashes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Oldest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Newest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Headers", "Docs": "", "Typewords": ["[]", "[]", "string"] }, { "Name": "SizeMin", "Docs": "", "Typewords": ["int64"] }, { "Name": "SizeMax", "Docs": "", "Typewords": ["int64"] }] },
+		"NotFilter": { "Name": "NotFilter", "Docs": "", "Fields": [{ "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "WordHashes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"Page": { "Name": "Page", "Docs": "", "Fields": [{ "Name": "AnchorMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Count", "Docs": "", "Typewords": ["int32"] }, { "Name": "DestMessageID", "Docs": "", "Typewords": ["int64"] }] },
-		"ParsedMessage": { "Name": "ParsedMessage", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }, { "Name": "Headers", "Docs": "", "Typewords": ["{}", "[]", "string"] }, { "Name": "ViewMode", "Docs": "", "Typewords": ["ViewMode"] }, { "Name": "Texts", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HasHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListReplyAddress", "Docs": "", "Typewords": ["nullable", "MessageAddress"] }, { "Name": "TextPaths", "Docs": "", "Typewords": ["[]", "[]", "int32"] }, { "Name": "HTMLPath", "Docs": "", "Typewords": ["[]", "int32"] }] },
+		"ParsedMessage": { "Name": "ParsedMessage", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }, { "Name": "Headers", "Docs": "", "Typewords": ["{}", "[]", "string"] }, { "Name": "ViewMode", "Docs": "", "Typewords": ["ViewMode"] }, { "Name": "Texts", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HasHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListReplyAddress", "Docs": "", "Typewords": ["nullable", "MessageAddress"] }, { "Name": "TextPaths", "Docs": "", "Typewords": ["[]", "[]", "int32"] }, { "Name": "HTMLPath", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "SMIMECertificate", "Docs": "", "Typewords": ["nullable", "SMIMECertificate"] }, { "Name": "SMIMEPinned", "Docs": "", "Typewords": ["string"] }] },
 		"Part": { "Name": "Part", "Docs": "", "Fields": [{ "Name": "BoundaryOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "HeaderOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "BodyOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "EndOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "RawLineCount", "Docs": "", "Typewords": ["int64"] }, { "Name": "DecodedSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "MediaType", "Docs": "", "Typewords": ["string"] }, { "Name": "MediaSubType", "Docs": "", "Typewords": ["string"] }, { "Name": "ContentTypeParams", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "ContentID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentDescription", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentTransferEncoding", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentDisposition", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentMD5", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentLanguage", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentLocation", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Envelope", "Docs": "", "Typewords": ["nullable", "Envelope"] }, { "Name": "Parts", "Docs": "", "Typewords": ["[]", "Part"] }, { "Name": "Message", "Docs": "", "Typewords": ["nullable", "Part"] }] },
 		"Envelope": { "Name": "Envelope", "Docs": "", "Fields": [{ "Name": "Date", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "Sender", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }] },
 		"Address": { "Name": "Address", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "User", "Docs": "", "Typewords": ["string"] }, { "Name": "Host", "Docs": "", "Typewords": ["string"] }] },
 		"MessageAddress": { "Name": "MessageAddress", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "User", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }] },
 		"Domain": { "Name": "Domain", "Docs": "", "Fields": [{ "Name": "ASCII", "Docs": "", "Typewords": ["string"] }, { "Name": "Unicode", "Docs": "", "Typewords": ["string"] }] },
+		"SMIMECertificate": { "Name": "SMIMECertificate", "Docs": "", "Fields": [{ "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Issuer", "Docs": "", "Typewords": ["string"] }, { "Name": "SerialNumber", "Docs": "", "Typewords": ["string"] }, { "Name": "NotBefore", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "NotAfter", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Chain", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"FromAddressSettings": { "Name": "FromAddressSettings", "Docs": "", "Fields": [{ "Name": "FromAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "ViewMode", "Docs": "", "Typewords": ["ViewMode"] }] },
-		"ComposeMessage": { "Name": "ComposeMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }] },
-		"SubmitMessage": { "Name": "SubmitMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "File"] }, { "Name": "ForwardAttachments", "Docs": "", "Typewords": ["ForwardAttachments"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureRelease", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "ArchiveThread", "Docs": "", "Typewords": ["bool"] }, { "Name": "ArchiveReferenceMailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }] },
-		"File": { "Name": "File", "Docs": "", "Fields": [{ "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"MessageJunkVerdict": { "Name": "MessageJunkVerdict", "Docs": "", "Fields": [{ "Name": "Accept", "Docs": "", "Typewords": ["bool"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }, { "Name": "Details", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Classified", "Docs": "", "Typewords": ["bool"] }, { "Name": "Score", "Docs": "", "Typewords": ["float64"] }, { "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "Significant", "Docs": "", "Typewords": ["bool"] }, { "Name": "HamWords", "Docs": "", "Typewords": ["[]", "MessageJunkWord"] }, { "Name": "SpamWords", "Docs": "", "Typewords": ["[]", "MessageJunkWord"] }] },
+		"MessageJunkWord": { "Name": "MessageJunkWord", "Docs": "", "Fields": [{ "Name": "Word", "Docs": "", "Typewords": ["string"] }, { "Name": "Score", "Docs": "", "Typewords": ["float64"] }] },
+		"ComposeMessage": { "Name": "ComposeMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftID", "Docs": "", "Typewords": ["int64"] }] },
+		"ResponseCompose": { "Name": "ResponseCompose", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "References", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "EditOffset", "Docs": "", "Typewords": ["int32"] }, { "Name": "HTMLBody", "Docs": "", "Typewords": ["string"] }, { "Name": "ForwardAttachments", "Docs": "", "Typewords": ["ForwardAttachments"] }] },
 		"ForwardAttachments": { "Name": "ForwardAttachments", "Docs": "", "Fields": [{ "Name": "MessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Paths", "Docs": "", "Typewords": ["[]", "[]", "int32"] }] },
+		"SubmitMessage": { "Name": "SubmitMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "HTMLBody", "Docs": "", "Typewords": ["string"] }, { "Name": "InlineFiles", "Docs": "", "Typewords": ["[]", "InlineFile"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "File"] }, { "Name": "ForwardAttachments", "Docs": "", "Typewords": ["ForwardAttachments"] }, { "Name": "AttachMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureRelease", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "ArchiveThread", "Docs": "", "Typewords": ["bool"] }, { "Name": "ArchiveReferenceMailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftID", "Docs": "", "Typewords": ["int64"] }, { "Name": "PGPSign", "Docs": "", "Typewords": ["bool"] }, { "Name": "PGPEncrypt", "Docs": "", "Typewords": ["bool"] }, { "Name": "PGPPassphrase", "Docs": "", "Typewords": ["string"] }] },
+		"InlineFile": { "Name": "InlineFile", "Docs": "", "Fields": [{ "Name": "ContentID", "Docs": "", "Typewords": ["string"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"File": { "Name": "File", "Docs": "", "Fields": [{ "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"SubmitResult": { "Name": "SubmitResult", "Docs": "", "Fields": [{ "Name": "QueueMsgIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "SentMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UndoUntil", "Docs": "", "Typewords": ["nullable", "timestamp"] }] },
+		"PGPResult": { "Name": "PGPResult", "Docs": "", "Fields": [{ "Name": "Decrypted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Signed", "Docs": "", "Typewords": ["bool"] }, { "Name": "SignatureValid", "Docs": "", "Typewords": ["bool"] }, { "Name": "SignatureError", "Docs": "", "Typewords": ["string"] }, { "Name": "SignerKeyID", "Docs": "", "Typewords": ["string"] }, { "Name": "SignerAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignerFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "SignerKeySource", "Docs": "", "Typewords": ["string"] }, { "Name": "Texts", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Unsubscribe": { "Name": "Unsubscribe", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Time", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "FromAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "ListID", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Method", "Docs": "", "Typewords": ["string"] }, { "Name": "Target", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"BulkAction": { "Name": "BulkAction", "Docs": "", "Fields": [{ "Name": "Op", "Docs": "", "Typewords": ["BulkOp"] }, { "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Label", "Docs": "", "Typewords": ["string"] }] },
 		"Mailbox": { "Name": "Mailbox", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "CreateSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Expunged", "Docs": "", "Typewords": ["bool"] }, { "Name": "ParentID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "UIDValidity", "Docs": "", "Typewords": ["uint32"] }, { "Name": "UIDNext", "Docs": "", "Typewords": ["UID"] }, { "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Sent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Trash", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HaveCounts", "Docs": "", "Typewords": ["bool"] }, { "Name": "Total", "Docs": "", "Typewords": ["int64"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["int64"] }, { "Name": "Unread", "Docs": "", "Typewords": ["int64"] }, { "Name": "Unseen", "Docs": "", "Typewords": ["int64"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }] },
+		"ThreadSummary": { "Name": "ThreadSummary", "Docs": "", "Fields": [{ "Name": "ThreadID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "MailboxIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Unread", "Docs": "", "Typewords": ["int32"] }, { "Name": "Participants", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Snippet", "Docs": "", "Typewords": ["string"] }, { "Name": "Latest", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Muted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Collapsed", "Docs": "", "Typewords": ["bool"] }] },
 		"RecipientSecurity": { "Name": "RecipientSecurity", "Docs": "", "Fields": [{ "Name": "STARTTLS", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "DNSSEC", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "DANE", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["SecurityResult"] }] },
-		"Settings": { "Name": "Settings", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["uint8"] }, { "Name": "Signature", "Docs": "", "Typewords": ["string"] }, { "Name": "Quoting", "Docs": "", "Typewords": ["Quoting"] }, { "Name": "ShowAddressSecurity", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoShowShortcuts", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHeaders", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Settings": { "Name": "Settings", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["uint8"] }, { "Name": "Signature", "Docs": "", "Typewords": ["string"] }, { "Name": "Quoting", "Docs": "", "Typewords": ["Quoting"] }, { "Name": "ShowAddressSecurity", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoShowShortcuts", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHeaders", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "UndoSendSeconds", "Docs": "", "Typewords": ["int32"] }, { "Name": "HTMLAllowedTags", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HTMLAllowedAttributes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "RemoteContentProxy", "Docs": "", "Typewords": ["bool"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "Label"] }, { "Name": "NotifyMailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "NotifySenders", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Label": { "Name": "Label", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Color", "Docs": "", "Typewords": ["string"] }] },
+		"Template": { "Name": "Template", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "TemplateAttachment"] }] },
+		"TemplateAttachment": { "Name": "TemplateAttachment", "Docs": "", "Fields": [{ "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"SharedTemplate": { "Name": "SharedTemplate", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }] },
+		"TemplateRef": { "Name": "TemplateRef", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }] },
+		"ComposeTemplate": { "Name": "ComposeTemplate", "Docs": "", "Fields": [{ "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "File"] }] },
 		"Ruleset": { "Name": "Ruleset", "Docs": "", "Fields": [{ "Name": "SMTPMailFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "HeadersRegexp", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListAllowDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "AcceptRejectsToMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ListAllowDNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
-		"EventStart": { "Name": "EventStart", "Docs": "", "Fields": [{ "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["MessageAddress"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "DomainAddressConfigs", "Docs": "", "Typewords": ["{}", "DomainAddressConfig"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "Mailbox"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Settings", "Docs": "", "Typewords": ["Settings"] }, { "Name": "AccountPath", "Docs": "", "Typewords": ["string"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }] },
+		"Rule": { "Name": "Rule", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Position", "Docs": "", "Typewords": ["int32"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Disabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["string"] }, { "Name": "SubjectContains", "Docs": "", "Typewords": ["string"] }, { "Name": "HasAttachment", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListID", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "MarkRead", "Docs": "", "Typewords": ["bool"] }, { "Name": "ForwardTo", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Delete", "Docs": "", "Typewords": ["bool"] }, { "Name": "Stop", "Docs": "", "Typewords": ["bool"] }] },
+		"SavedSearch": { "Name": "SavedSearch", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Search", "Docs": "", "Typewords": ["string"] }, { "Name": "Filter", "Docs": "", "Typewords": ["Filter"] }, { "Name": "NotFilter", "Docs": "", "Typewords": ["NotFilter"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "Unseen", "Docs": "", "Typewords": ["int32"] }] },
+		"Draft": { "Name": "Draft", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Version", "Docs": "", "Typewords": ["int64"] }, { "Name": "Updated", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Message", "Docs": "", "Typewords": ["ComposeMessage"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "DraftAttachment"] }] },
+		"DraftAttachment": { "Name": "DraftAttachment", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "ContentType", "Docs": "", "Typewords": ["string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }] },
+		"Delegation": { "Name": "Delegation", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Write", "Docs": "", "Typewords": ["bool"] }, { "Name": "Send", "Docs": "", "Typewords": ["string"] }] },
+		"DelegatedAccess": { "Name": "DelegatedAccess", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Write", "Docs": "", "Typewords": ["bool"] }, { "Name": "Send", "Docs": "", "Typewords": ["string"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"MessageItem": { "Name": "MessageItem", "Docs": "", "Fields": [{ "Name": "Message", "Docs": "", "Typewords": ["Message"] }, { "Name": "Envelope", "Docs": "", "Typewords": ["MessageEnvelope"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "Attachment"] }, { "Name": "IsSigned", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsEncrypted", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMIME", "Docs": "", "Typewords": ["nullable", "SMIMEStatus"] }, { "Name": "Calendar", "Docs": "", "Typewords": ["nullable", "CalendarInvite"] }, { "Name": "Unsubscribe", "Docs": "", "Typewords": ["nullable", "ListUnsubscribe"] }, { "Name": "Auth", "Docs": "", "Typewords": ["nullable", "AuthResults"] }, { "Name": "MatchQuery", "Docs": "", "Typewords": ["bool"] }, { "Name": "MoreHeaders", "Docs": "", "Typewords": ["[]", "[]", "string"] }, { "Name": "SenderWarnings", "Docs": "", "Typewords": ["[]", "SenderWarning"] }] },
+		"Message": { "Name": "Message", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UID", "Docs": "", "Typewords": ["UID"] }, { "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "CreateSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Expunged", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsReject", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxOrigID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxDestinedID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SaveDate", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked1", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked2", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked3", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MailFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "RcptToLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RcptToDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MsgFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromOrgDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLOValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MsgFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "EHLOValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MailFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MsgFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "DKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "OrigEHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "OrigDKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Auth", "Docs": "", "Typewords": ["nullable", "MessageAuth"] }, { "Name": "ReputationJunk", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "SubjectBase", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageHash", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ThreadID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ThreadParentIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "ThreadMissingLink", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadMuted", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadCollapsed", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsMailingList", "Docs": "", "Typewords": ["bool"] }, { "Name": "DSN", "Docs": "", "Typewords": ["bool"] }, { "Name": "ReceivedTLSVersion", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedTLSCipherSuite", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedRequireTLS", "Docs": "", "Typewords": ["bool"] }, { "Name": "Seen", "Docs": "", "Typewords": ["bool"] }, { "Name": "Answered", "Docs": "", "Typewords": ["bool"] }, { "Name": "Flagged", "Docs": "", "Typewords": ["bool"] }, { "Name": "Forwarded", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Phishing", "Docs": "", "Typewords": ["bool"] }, { "Name": "MDNSent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "TrainedJunk", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "StoredSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "Compressed", "Docs": "", "Typewords": ["bool"] }, { "Name": "Preview", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ParsedBuf", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"MessageAuth": { "Name": "MessageAuth", "Docs": "", "Fields": [{ "Name": "IPRev", "Docs": "", "Typewords": ["string"] }, { "Name": "IPRevAuthentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "SPF", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFIdentity", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFAuthentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["[]", "MessageAuthDKIM"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["string"] }, { "Name": "DMARCDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "DMARCPolicy", "Docs": "", "Typewords": ["string"] }, { "Name": "DMARCAuthentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "DMARCOverrides", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ARCSealer", "Docs": "", "Typewords": ["string"] }, { "Name": "BIMI", "Docs": "", "Typewords": ["string"] }, { "Name": "BIMIDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "DNSBLs", "Docs": "", "Typewords": ["[]", "MessageAuthDNSBL"] }] },
+		"MessageAuthDKIM": { "Name": "MessageAuthDKIM", "Docs": "", "Fields": [{ "Name": "Status", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Selector", "Docs": "", "Typewords": ["string"] }, { "Name": "Algorithm", "Docs": "", "Typewords": ["string"] }, { "Name": "Identity", "Docs": "", "Typewords": ["string"] }, { "Name": "Authentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"MessageAuthDNSBL": { "Name": "MessageAuthDNSBL", "Docs": "", "Fields": [{ "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Status", "Docs": "", "Typewords": ["string"] }] },
+		"MessageEnvelope": { "Name": "MessageEnvelope", "Docs": "", "Fields": [{ "Name": "Date", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "Sender", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }] },
+		"Attachment": { "Name": "Attachment", "Docs": "", "Fields": [{ "Name": "Path", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }] },
+		"SMIMEStatus": { "Name": "SMIMEStatus", "Docs": "", "Fields": [{ "Name": "Valid", "Docs": "", "Typewords": ["bool"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "SignerAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignerFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "Fingerprint", "Docs": "", "Typewords": ["string"] }] },
+		"CalendarInvite": { "Name": "CalendarInvite", "Docs": "", "Fields": [{ "Name": "Path", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "Method", "Docs": "", "Typewords": ["string"] }, { "Name": "UID", "Docs": "", "Typewords": ["string"] }, { "Name": "Sequence", "Docs": "", "Typewords": ["int32"] }, { "Name": "Summary", "Docs": "", "Typewords": ["string"] }, { "Name": "Location", "Docs": "", "Typewords": ["string"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "Start", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "End", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "AllDay", "Docs": "", "Typewords": ["bool"] }, { "Name": "Organizer", "Docs": "", "Typewords": ["CalendarAttendee"] }, { "Name": "Attendees", "Docs": "", "Typewords": ["[]", "CalendarAttendee"] }] },
+		"CalendarAttendee": { "Name": "CalendarAttendee", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "Status", "Docs": "", "Typewords": ["string"] }, { "Name": "Role", "Docs": "", "Typewords": ["string"] }] },
+		"ListUnsubscribe": { "Name": "ListUnsubscribe", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailto", "Docs": "", "Typewords": ["string"] }, { "Name": "OneClick", "Docs": "", "Typewords": ["bool"] }] },
+		"AuthResults": { "Name": "AuthResults", "Docs": "", "Fields": [{ "Name": "IPRev", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "SPF", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["[]", "AuthCheck"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "BIMI", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "BIMILogoURL", "Docs": "", "Typewords": ["string"] }, { "Name": "BIMILogoPath", "Docs": "", "Typewords": ["string"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }, { "Name": "ReasonDetails", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"AuthCheck": { "Name": "AuthCheck", "Docs": "", "Fields": [{ "Name": "Result", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }] },
+		"SenderWarning": { "Name": "SenderWarning", "Docs": "", "Fields": [{ "Name": "Kind", "Docs": "", "Typewords": ["SenderWarningKind"] }, { "Name": "Similar", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }] },
+		"EventStart": { "Name": "EventStart", "Docs": "", "Fields": [{ "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["MessageAddress"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "DomainAddressConfigs", "Docs": "", "Typewords": ["{}", "DomainAddressConfig"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "Mailbox"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Settings", "Docs": "", "Typewords": ["Settings"] }, { "Name": "Identities", "Docs": "", "Typewords": ["[]", "Identity"] }, { "Name": "PGPAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Delegated", "Docs": "", "Typewords": ["[]", "DelegatedAccess"] }, { "Name": "SavedSearches", "Docs": "", "Typewords": ["[]", "SavedSearch"] }, { "Name": "AccountPath", "Docs": "", "Typewords": ["string"] }, { "Name": "QuotaWarning", "Docs": "", "Typewords": ["string"] }, { "Name": "Impersonation", "Docs": "", "Typewords": ["nullable", "Impersonation"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }, { "Name": "SearchIndexSalt", "Docs": "", "Typewords": ["string"] }, { "Name": "Resumed", "Docs": "", "Typewords": ["bool"] }] },
 		"DomainAddressConfig": { "Name": "DomainAddressConfig", "Docs": "", "Fields": [{ "Name": "LocalpartCatchallSeparators", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "LocalpartCaseSensitive", "Docs": "", "Typewords": ["bool"] }] },
+		"Identity": { "Name": "Identity", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "DisplayName", "Docs": "", "Typewords": ["string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignatureHTML", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyQuoting", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Default", "Docs": "", "Typewords": ["bool"] }] },
+		"Impersonation": { "Name": "Impersonation", "Docs": "", "Fields": [{ "Name": "By", "Docs": "", "Typewords": ["string"] }, { "Name": "ReadOnly", "Docs": "", "Typewords": ["bool"] }, { "Name": "End", "Docs": "", "Typewords": ["timestamp"] }] },
 		"EventViewErr": { "Name": "EventViewErr", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RequestID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Err", "Docs": "", "Typewords": ["string"] }] },
 		"EventViewReset": { "Name": "EventViewReset", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RequestID", "Docs": "", "Typewords": ["int64"] }] },
 		"EventViewMsgs": { "Name": "EventViewMsgs", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RequestID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageItems", "Docs": "", "Typewords": ["[]", "[]", "MessageItem"] }, { "Name": "ParsedMessage", "Docs": "", "Typewords": ["nullable", "ParsedMessage"] }, { "Name": "ViewEnd", "Docs": "", "Typewords": ["bool"] }] },
-		"MessageItem": { "Name": "MessageItem", "Docs": "", "Fields": [{ "Name": "Message", "Docs": "", "Typewords": ["Message"] }, { "Name": "Envelope", "Docs": "", "Typewords": ["MessageEnvelope"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "Attachment"] }, { "Name": "IsSigned", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsEncrypted", "Docs": "", "Typewords": ["bool"] }, { "Name": "MatchQuery", "Docs": "", "Typewords": ["bool"] }, { "Name": "MoreHeaders", "Docs": "", "Typewords": ["[]", "[]", "string"] }] },
-		"Message": { "Name": "Message", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UID", "Docs": "", "Typewords": ["UID"] }, { "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "CreateSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Expunged", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsReject", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxOrigID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxDestinedID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SaveDate", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked1", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked2", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked3", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MailFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "RcptToLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RcptToDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MsgFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromOrgDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLOValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MsgFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "EHLOValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MailFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MsgFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "DKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "OrigEHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "OrigDKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "SubjectBase", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageHash", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ThreadID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ThreadParentIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "ThreadMissingLink", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadMuted", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadCollapsed", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsMailingList", "Docs": "", "Typewords": ["bool"] }, { "Name": "DSN", "Docs": "", "Typewords": ["bool"] }, { "Name": "ReceivedTLSVersion", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedTLSCipherSuite", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedRequireTLS", "Docs": "", "Typewords": ["bool"] }, { "Name": "Seen", "Docs": "", "Typewords": ["bool"] }, { "Name": "Answered", "Docs": "", "Typewords": ["bool"] }, { "Name": "Flagged", "Docs": "", "Typewords": ["bool"] }, { "Name": "Forwarded", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Phishing", "Docs": "", "Typewords": ["bool"] }, { "Name": "MDNSent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "TrainedJunk", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Preview", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ParsedBuf", "Docs": "", "Typewords": ["nullable", "string"] }] },
-		"MessageEnvelope": { "Name": "MessageEnvelope", "Docs": "", "Fields": [{ "Name": "Date", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "Sender", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }] },
-		"Attachment": { "Name": "Attachment", "Docs": "", "Fields": [{ "Name": "Path", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }] },
 		"EventViewChanges": { "Name": "EventViewChanges", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Changes", "Docs": "", "Typewords": ["[]", "[]", "any"] }] },
 		"ChangeMsgAdd": { "Name": "ChangeMsgAdd", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UID", "Docs": "", "Typewords": ["UID"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Flags", "Docs": "", "Typewords": ["Flags"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MessageCountIMAP", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Unseen", "Docs": "", "Typewords": ["uint32"] }, { "Name": "MessageItems", "Docs": "", "Typewords": ["[]", "MessageItem"] }] },
 		"Flags": { "Name": "Flags", "Docs": "", "Fields": [{ "Name": "Seen", "Docs": "", "Typewords": ["bool"] }, { "Name": "Answered", "Docs": "", "Typewords": ["bool"] }, { "Name": "Flagged", "Docs": "", "Typewords": ["bool"] }, { "Name": "Forwarded", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Phishing", "Docs": "", "Typewords": ["bool"] }, { "Name": "MDNSent", "Docs": "", "Typewords": ["bool"] }] },
@@ -335,6 +395,9 @@ var api;
 		"ChangeMailboxSpecialUse": { "Name": "ChangeMailboxSpecialUse", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "SpecialUse", "Docs": "", "Typewords": ["SpecialUse"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }] },
 		"SpecialUse": { "Name": "SpecialUse", "Docs": "", "Fields": [{ "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Sent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Trash", "Docs": "", "Typewords": ["bool"] }] },
 		"ChangeMailboxKeywords": { "Name": "ChangeMailboxKeywords", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"EventSavedSearches": { "Name": "EventSavedSearches", "Docs": "", "Fields": [{ "Name": "SavedSearches", "Docs": "", "Typewords": ["[]", "SavedSearch"] }] },
+		"EventBulkProgress": { "Name": "EventBulkProgress", "Docs": "", "Fields": [{ "Name": "Op", "Docs": "", "Typewords": ["BulkOp"] }, { "Name": "Done", "Docs": "", "Typewords": ["int32"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }] },
+		"ViewResume": { "Name": "ViewResume", "Docs": "", "Fields": [{ "Name": "LastEventID", "Docs": "", "Typewords": ["int64"] }, { "Name": "LastMessageReceived", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ViewEnd", "Docs": "", "Typewords": ["bool"] }] },
 		"ModSeq": { "Name": "ModSeq", "Docs": "", "Values": null },
 		"UID": { "Name": "UID", "Docs": "", "Values": null },
 		"Validation": { "Name": "Validation", "Docs": "", "Values": [{ "Name": "ValidationUnknown", "Value": 0, "Docs": "" }, { "Name": "ValidationStrict", "Value": 1, "Docs": "" }, { "Name": "ValidationDMARC", "Value": 2, "Docs": "" }, { "Name": "ValidationRelaxed", "Value": 3, "Docs": "" }, { "Name": "ValidationPass", "Value": 4, "Docs": "" }, { "Name": "ValidationNeutral", "Value": 5, "Docs": "" }, { "Name": "ValidationTemperror", "Value": 6, "Docs": "" }, { "Name": "ValidationPermerror", "Value": 7, "Docs": "" }, { "Name": "ValidationFail", "Value": 8, "Docs": "" }, { "Name": "ValidationSoftfail", "Value": 9, "Docs": "" }, { "Name": "ValidationNone", "Value": 10, "Docs": "" }] },
@@ -342,11 +405,16 @@ var api;
 		"ThreadMode": { "Name": "ThreadMode", "Docs": "", "Values": [{ "Name": "ThreadOff", "Value": "off", "Docs": "" }, { "Name": "ThreadOn", "Value": "on", "Docs": "" }, { "Name": "ThreadUnread", "Value": "unread", "Docs": "" }] },
 		"AttachmentType": { "Name": "AttachmentType", "Docs": "", "Values": [{ "Name": "AttachmentIndifferent", "Value": "", "Docs": "" }, { "Name": "AttachmentNone", "Value": "none", "Docs": "" }, { "Name": "AttachmentAny", "Value": "any", "Docs": "" }, { "Name": "AttachmentImage", "Value": "image", "Docs": "" }, { "Name": "AttachmentPDF", "Value": "pdf", "Docs": "" }, { "Name": "AttachmentArchive", "Value": "archive", "Docs": "" }, { "Name": "AttachmentSpreadsheet", "Value": "spreadsheet", "Docs": "" }, { "Name": "AttachmentDocument", "Value": "document", "Docs": "" }, { "Name": "AttachmentPresentation", "Value": "presentation", "Docs": "" }] },
 		"ViewMode": { "Name": "ViewMode", "Docs": "", "Values": [{ "Name": "ModeText", "Value": "text", "Docs": "" }, { "Name": "ModeHTML", "Value": "html", "Docs": "" }, { "Name": "ModeHTMLExt", "Value": "htmlext", "Docs": "" }] },
+		"ResponseMode": { "Name": "ResponseMode", "Docs": "", "Values": [{ "Name": "ResponseReply", "Value": "reply", "Docs": "" }, { "Name": "ResponseReplyAll", "Value": "replyall", "Docs": "" }, { "Name": "ResponseReplyList", "Value": "replylist", "Docs": "" }, { "Name": "ResponseForward", "Value": "forward", "Docs": "" }] },
+		"BulkOp": { "Name": "BulkOp", "Docs": "", "Values": [{ "Name": "BulkMarkRead", "Value": "markread", "Docs": "" }, { "Name": "BulkMarkUnread", "Value": "markunread", "Docs": "" }, { "Name": "BulkMove", "Value": "move", "Docs": "" }, { "Name": "BulkLabel", "Value": "label", "Docs": "" }, { "Name": "BulkDelete", "Value": "delete", "Docs": "" }] },
 		"SecurityResult": { "Name": "SecurityResult", "Docs": "", "Values": [{ "Name": "SecurityResultError", "Value": "error", "Docs": "" }, { "Name": "SecurityResultNo", "Value": "no", "Docs": "" }, { "Name": "SecurityResultYes", "Value": "yes", "Docs": "" }, { "Name": "SecurityResultUnknown", "Value": "unknown", "Docs": "" }] },
 		"Quoting": { "Name": "Quoting", "Docs": "", "Values": [{ "Name": "Default", "Value": "", "Docs": "" }, { "Name": "Bottom", "Value": "bottom", "Docs": "" }, { "Name": "Top", "Value": "top", "Docs": "" }] },
 		"Localpart": { "Name": "Localpart", "Docs": "", "Values": null },
+		"SenderWarningKind": { "Name": "SenderWarningKind", "Docs": "", "Values": [{ "Name": "SenderWarningDisplayName", "Value": "displayname", "Docs": "" }, { "Name": "SenderWarningLookalike", "Value": "lookalike", "Docs": "" }, { "Name": "SenderWarningReplyTo", "Value": "replyto", "Docs": "" }] },
 	};
 	api.parser = {
+		PasskeyGetOptions: (v) => api.parse("PasskeyGetOptions", v),
+		PasskeyAssertion: (v) => api.parse("PasskeyAssertion", v),
 		Request: (v) => api.parse("Request", v),
 		Query: (v) => api.parse("Query", v),
 		Filter: (v) => api.parse("Filter", v),
@@ -358,24 +426,58 @@ var api;
 		Address: (v) => api.parse("Address", v),
 		MessageAddress: (v) => api.parse("MessageAddress", v),
 		Domain: (v) => api.parse("Domain", v),
+		SMIMECertificate: (v) => api.parse("SMIMECertificate", v),
 		FromAddressSettings: (v) => api.parse("FromAddressSettings", v),
+		MessageJunkVerdict: (v) => api.parse("MessageJunkVerdict", v),
+		MessageJunkWord: (v) => api.parse("MessageJunkWord", v),
 		ComposeMessage: (v) => api.parse("ComposeMessage", v),
+		ResponseCompose: (v) => api.parse("ResponseCompose", v),
+		ForwardAttachments: (v) => api.parse("ForwardAttachments", v),
 		SubmitMessage: (v) => api.parse("SubmitMessage", v),
+		InlineFile: (v) => api.parse("InlineFile", v),
 		File: (v) => api.parse("File", v),
-		ForwardAttachments: (v) => api.parse("ForwardAttachments", v),
+		SubmitResult: (v) => api.parse("SubmitResult", v),
+		PGPResult: (v) => api.parse("PGPResult", v),
+		Unsubscribe: (v) => api.parse("Unsubscribe", v),
+		BulkAction: (v) => api.parse("BulkAction", v),
 		Mailbox: (v) => api.parse("Mailbox", v),
+		ThreadSummary: (v) => api.parse("ThreadSummary", v),
 		RecipientSecurity: (v) => api.parse("RecipientSecurity", v),
 		Settings: (v) => api.parse("Settings", v),
+		Label: (v) => api.parse("Label", v),
+		Template: (v) => api.parse("Template", v),
+		TemplateAttachment: (v) => api.parse("TemplateAttachment", v),
+		SharedTemplate: (v) => api.parse("SharedTemplate", v),
+		TemplateRef: (v) => api.parse("TemplateRef", v),
+		ComposeTemplate: (v) => api.parse("ComposeTemplate", v),
 		Ruleset: (v) => api.parse("Ruleset", v),
+		Rule: (v) => api.parse("Rule", v),
+		SavedSearch: (v) => api.parse("SavedSearch", v),
+		Draft: (v) => api.parse("Draft", v),
+		DraftAttachment: (v) => api.parse("DraftAttachment", v),
+		Delegation: (v) => api.parse("Delegation", v),
+		DelegatedAccess: (v) => api.parse("DelegatedAccess", v),
+		MessageItem: (v) => api.parse("MessageItem", v),
+		Message: (v) => api.parse("Message", v),
+		MessageAuth: (v) => api.parse("MessageAuth", v),
+		MessageAuthDKIM: (v) => api.parse("MessageAuthDKIM", v),
+		MessageAuthDNSBL: (v) => api.parse("MessageAuthDNSBL", v),
+		MessageEnvelope: (v) => api.parse("MessageEnvelope", v),
+		Attachment: (v) => api.parse("Attachment", v),
+		SMIMEStatus: (v) => api.parse("SMIMEStatus", v),
+		CalendarInvite: (v) => api.parse("CalendarInvite", v),
+		CalendarAttendee: (v) => api.parse("CalendarAttendee", v),
+		ListUnsubscribe: (v) => api.parse("ListUnsubscribe", v),
+		AuthResults: (v) => api.parse("AuthResults", v),
+		AuthCheck: (v) => api.parse("AuthCheck", v),
+		SenderWarning: (v) => api.parse("SenderWarning", v),
 		EventStart: (v) => api.parse("EventStart", v),
 		DomainAddressConfig: (v) => api.parse("DomainAddressConfig", v),
+		Identity: (v) => api.parse("Identity", v),
+		Impersonation: (v) => api.parse("Impersonation", v),
 		EventViewErr: (v) => api.parse("EventViewErr", v),
 		EventViewReset: (v) => api.parse("EventViewReset", v),
 		EventViewMsgs: (v) => api.parse("EventViewMsgs", v),
-		MessageItem: (v) => api.parse("MessageItem", v),
-		Message: (v) => api.parse("Message", v),
-		MessageEnvelope: (v) => api.parse("MessageEnvelope", v),
-		Attachment: (v) => api.parse("Attachment", v),
 		EventViewChanges: (v) => api.parse("EventViewChanges", v),
 		ChangeMsgAdd: (v) => api.parse("ChangeMsgAdd", v),
 		Flags: (v) => api.parse("Flags", v),
@@ -389,6 +491,9 @@ var api;
 		ChangeMailboxSpecialUse: (v) => api.parse("ChangeMailboxSpecialUse", v),
 		SpecialUse: (v) => api.parse("SpecialUse", v),
 		ChangeMailboxKeywords: (v) => api.parse("ChangeMailboxKeywords", v),
+		EventSavedSearches: (v) => api.parse("EventSavedSearches", v),
+		EventBulkProgress: (v) => api.parse("EventBulkProgress", v),
+		ViewResume: (v) => api.parse("ViewResume", v),
 		ModSeq: (v) => api.parse("ModSeq", v),
 		UID: (v) => api.parse("UID", v),
 		Validation: (v) => api.parse("Validation", v),
@@ -396,9 +501,12 @@ var api;
 		ThreadMode: (v) => api.parse("ThreadMode", v),
 		AttachmentType: (v) => api.parse("AttachmentType", v),
 		ViewMode: (v) => api.parse("ViewMode", v),
+		ResponseMode: (v) => api.parse("ResponseMode", v),
+		BulkOp: (v) => api.parse("BulkOp", v),
 		SecurityResult: (v) => api.parse("SecurityResult", v),
 		Quoting: (v) => api.parse("Quoting", v),
 		Localpart: (v) => api.parse("Localpart", v),
+		SenderWarningKind: (v) => api.parse("SenderWarningKind", v),
 	};
 	let defaultOptions = { slicesNullable: true, mapsNullable: true, nullableOptional: true };
 	class Client {
@@ -432,12 +540,44 @@ var api;
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Login returns a session token for the credentials, or fails with error code
-		// "user:badLogin". Call LoginPrep to get a loginToken.
-		async Login(loginToken, username, password) {
+		// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+		// authentication is enabled, totp must be a code from the authenticator app,
+		// otherwise the login fails with error code "user:totpRequired".
+		async Login(loginToken, username, password, totp) {
 			const fn = "Login";
-			const paramTypes = [["string"], ["string"], ["string"]];
+			const paramTypes = [["string"], ["string"], ["string"], ["string"]];
+			const returnTypes = [["CSRFToken"]];
+			const params = [loginToken, username, password, totp];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLoginStart returns the parameters for the browser to login with a
+		// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+		// of the browser.
+		async PasskeyLoginStart(loginToken) {
+			const fn = "PasskeyLoginStart";
+			const paramTypes = [["string"]];
+			const returnTypes = [["PasskeyGetOptions"]];
+			const params = [loginToken];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLogin returns a session token for a passkey login started with
+		// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+		// password, the passkey must verify the user, e.g. with a PIN. With username and
+		// password, the passkey is the second factor.
+		async PasskeyLogin(loginToken, username, password, assertion) {
+			const fn = "PasskeyLogin";
+			const paramTypes = [["string"], ["string"], ["string"], ["PasskeyAssertion"]];
 			const returnTypes = [["CSRFToken"]];
-			const params = [loginToken, username, password];
+			const params = [loginToken, username, password, assertion];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// OIDCEnabled returns whether logging in through an OpenID Connect identity
+		// provider is possible, by sending the browser to "oidc/login".
+		async OIDCEnabled() {
+			const fn = "OIDCEnabled";
+			const paramTypes = [];
+			const returnTypes = [["bool"]];
+			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Logout invalidates the session token.
@@ -496,6 +636,17 @@ var api;
 			const params = [fas];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// SMIMEPin pins the key of the certificate that signed the message for the From
+		// address of the message. Messages from the address signed with another key are
+		// shown with a warning. Only messages with a valid signature by a certificate for
+		// the From address can be pinned.
+		async SMIMEPin(msgID) {
+			const fn = "SMIMEPin";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [msgID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageFindMessageID looks up a message by Message-Id header, and returns the ID
 		// of the message in storage. Used when opening a previously saved draft message
 		// for editing again.
@@ -507,6 +658,17 @@ var api;
 			const params = [messageID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageJunkVerdict returns the outcome of the junk analysis done when the
+		// message was delivered over SMTP, with the signals and junk filter words that
+		// contributed, for explaining why a message was (not) marked as junk. Returns
+		// nil for messages without stored verdict, e.g. sent or imported messages.
+		async MessageJunkVerdict(msgID) {
+			const fn = "MessageJunkVerdict";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["nullable", "MessageJunkVerdict"]];
+			const params = [msgID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageCompose composes a message and saves it to the mailbox. Used for
 		// saving draft messages.
 		async MessageCompose(m, mailboxID) {
@@ -516,6 +678,18 @@ var api;
 			const params = [m, mailboxID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageResponse returns a prepared reply or forward for a message. If selection
+		// is not empty, it is quoted instead of the text of the message, e.g. text the
+		// user selected. The response is sent with MessageSubmit, with ResponseMessageID
+		// set, and for forwards optionally with the attachments in ForwardAttachments, or
+		// the entire message with AttachMessageID.
+		async MessageResponse(messageID, mode, selection) {
+			const fn = "MessageResponse";
+			const paramTypes = [["int64"], ["ResponseMode"], ["string"]];
+			const returnTypes = [["ResponseCompose"]];
+			const params = [messageID, mode, selection];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageSubmit sends a message by submitting it the outgoing email queue. The
 		// message is sent to all addresses listed in the To, Cc and Bcc addresses, without
 		// Bcc message header.
@@ -523,13 +697,83 @@ var api;
 		// If a Sent mailbox is configured, messages are added to it after submitting
 		// to the delivery queue. If Bcc addresses were present, a header is prepended
 		// to the message stored in the Sent mailbox.
+		// 
+		// If UndoSendSeconds is set in the settings and no FutureRelease is requested,
+		// delivery is held back for that many seconds, during which MessageSubmitUndo
+		// can cancel it.
 		async MessageSubmit(m) {
 			const fn = "MessageSubmit";
 			const paramTypes = [["SubmitMessage"]];
-			const returnTypes = [];
+			const returnTypes = [["SubmitResult"]];
 			const params = [m];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageSubmitUndo cancels delivery of a message submitted with MessageSubmit
+		// that is still held back, see SubmitResult. The copy of the message in the Sent
+		// mailbox, if any, is moved to the Drafts mailbox and marked as draft, so it can
+		// be edited and sent again.
+		async MessageSubmitUndo(queueMsgIDs, sentMessageID) {
+			const fn = "MessageSubmitUndo";
+			const paramTypes = [["[]", "int64"], ["int64"]];
+			const returnTypes = [];
+			const params = [queueMsgIDs, sentMessageID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// MessagePGP verifies the signature of an OpenPGP signed message, or decrypts an
+		// OpenPGP encrypted message and verifies the signature inside it, if any. The
+		// private keys of the account are decrypted with passphrase, it can be empty for
+		// keys without passphrase. Signatures are verified with the keys stored in the
+		// account, and with keys from the Web Key Directory of the From address.
+		async MessagePGP(msgID, passphrase) {
+			const fn = "MessagePGP";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [["PGPResult"]];
+			const params = [msgID, passphrase];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// CalendarReply responds to the meeting invitation in a message, with status
+		// ACCEPTED, TENTATIVE or DECLINED. The reply is sent to the organizer, from the
+		// first attendee address of the account.
+		async CalendarReply(msgID, status) {
+			const fn = "CalendarReply";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [["SubmitResult"]];
+			const params = [msgID, status];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// MessageUnsubscribe unsubscribes from the mailing list of a message with a
+		// List-Unsubscribe header. Method "oneclick" makes an HTTPS POST request to the
+		// URL from the header, RFC 8058. Method "mailto" sends an unsubscribe message to
+		// the address from the header, from the address of the account the message was
+		// sent to. The request is added to the unsubscribe history of the sender, also
+		// when a one-click request fails.
+		async MessageUnsubscribe(msgID, method) {
+			const fn = "MessageUnsubscribe";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [["Unsubscribe"]];
+			const params = [msgID, method];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// UnsubscribeHistory returns the unsubscribe requests made for messages from an
+		// address, most recent first.
+		async UnsubscribeHistory(fromAddress) {
+			const fn = "UnsubscribeHistory";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "Unsubscribe"]];
+			const params = [fromAddress];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderBlock adds the message From address of a message, or its domain if
+		// domain is set, to the sender lists of the account with action "reject",
+		// causing future messages from the sender to be rejected during SMTP. The
+		// pattern of the added entry is returned.
+		async SenderBlock(msgID, domain) {
+			const fn = "SenderBlock";
+			const paramTypes = [["int64"], ["bool"]];
+			const returnTypes = [["string"]];
+			const params = [msgID, domain];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageMove moves messages to another mailbox. If the message is already in
 		// the mailbox an error is returned.
 		async MessageMove(messageIDs, mailboxID) {
@@ -573,6 +817,19 @@ var api;
 			const params = [mailboxIDs];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageBulk applies an action to all messages matching the filters of the
+		// query, e.g. the current view of the client, without the client having to
+		// fetch and send all message IDs. Messages are changed in batches, each in its
+		// own transaction. If sseID is nonzero, EventBulkProgress events are sent on the
+		// SSE connection after each batch. Deleting is permanent. The number of messages
+		// that were changed is returned.
+		async MessageBulk(sseID, query, action) {
+			const fn = "MessageBulk";
+			const paramTypes = [["int64"], ["Query"], ["BulkAction"]];
+			const returnTypes = [["int32"]];
+			const params = [sseID, query, action];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MailboxCreate creates a new mailbox.
 		async MailboxCreate(name) {
 			const fn = "MailboxCreate";
@@ -608,8 +865,9 @@ var api;
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// CompleteRecipient returns autocomplete matches for a recipient, returning the
-		// matches, most recently used first, and whether this is the full list and further
-		// requests for longer prefixes aren't necessary.
+		// matches, most recently used first followed by matching contacts from the
+		// address books, and whether this is the full list and further requests for
+		// longer prefixes aren't necessary.
 		async CompleteRecipient(search) {
 			const fn = "CompleteRecipient";
 			const paramTypes = [["string"]];
@@ -644,6 +902,35 @@ var api;
 			const params = [messageIDs, mute];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Threads returns conversations that have at least one message in the mailbox, or
+		// in any mailbox if mailboxID is 0. Threads are ordered by their most recent
+		// message, newest first. The first offset threads are skipped, at most limit
+		// threads are returned.
+		async Threads(mailboxID, offset, limit) {
+			const fn = "Threads";
+			const paramTypes = [["int64"], ["int32"], ["int32"]];
+			const returnTypes = [["[]", "ThreadSummary"]];
+			const params = [mailboxID, offset, limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ThreadsCollapse sets the collapsed state of all messages in the threads, across
+		// all mailboxes.
+		async ThreadsCollapse(threadIDs, collapse) {
+			const fn = "ThreadsCollapse";
+			const paramTypes = [["[]", "int64"], ["bool"]];
+			const returnTypes = [];
+			const params = [threadIDs, collapse];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ThreadsMute sets the muted state of all messages in the threads, across all
+		// mailboxes. If threads are muted, they are also marked collapsed.
+		async ThreadsMute(threadIDs, mute) {
+			const fn = "ThreadsMute";
+			const paramTypes = [["[]", "int64"], ["bool"]];
+			const returnTypes = [];
+			const params = [threadIDs, mute];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// RecipientSecurity looks up security properties of the address in the
 		// single-address message addressee (as it appears in a To/Cc/Bcc/etc header).
 		async RecipientSecurity(messageAddressee) {
@@ -669,6 +956,43 @@ var api;
 			const params = [settings];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Templates returns the message templates of the account, and the templates
+		// shared by domains the account has an address in.
+		async Templates() {
+			const fn = "Templates";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Template"], ["[]", "SharedTemplate"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TemplateSave adds a template to the account if its ID is zero, and otherwise
+		// updates the existing template. The saved template is returned.
+		async TemplateSave(t) {
+			const fn = "TemplateSave";
+			const paramTypes = [["Template"]];
+			const returnTypes = [["Template"]];
+			const params = [t];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TemplateRemove removes a template from the account.
+		async TemplateRemove(templateID) {
+			const fn = "TemplateRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [templateID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TemplateApply returns the subject, text and attachments of a template for
+		// composing a message from fromAddress to recipients "to", with placeholders
+		// replaced. Placeholders for the recipient are replaced with the name and address
+		// of the first recipient.
+		async TemplateApply(ref, fromAddress, to) {
+			const fn = "TemplateApply";
+			const paramTypes = [["TemplateRef"], ["string"], ["[]", "string"]];
+			const returnTypes = [["ComposeTemplate"]];
+			const params = [ref, fromAddress, to];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		async RulesetSuggestMove(msgID, mbSrcID, mbDstID) {
 			const fn = "RulesetSuggestMove";
 			const paramTypes = [["int64"], ["int64"], ["int64"]];
@@ -704,11 +1028,265 @@ var api;
 			const params = [mailboxID, toMailbox];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Rules returns the message rules of the account, in order of evaluation.
+		async Rules() {
+			const fn = "Rules";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Rule"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RuleSave adds a rule to the account if its ID is zero, and otherwise updates
+		// the existing rule. The saved rule is returned.
+		async RuleSave(r) {
+			const fn = "RuleSave";
+			const paramTypes = [["Rule"]];
+			const returnTypes = [["Rule"]];
+			const params = [r];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RuleRemove removes a rule from the account.
+		async RuleRemove(ruleID) {
+			const fn = "RuleRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [ruleID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RuleRun applies the enabled rules to the existing messages in a mailbox, moving
+		// messages and marking them read. Messages are not forwarded. The number of
+		// messages that matched a rule is returned.
+		async RuleRun(mailboxID) {
+			const fn = "RuleRun";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["int32"]];
+			const params = [mailboxID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SavedSearches returns the saved searches of the account, with message counts.
+		async SavedSearches() {
+			const fn = "SavedSearches";
+			const paramTypes = [];
+			const returnTypes = [["[]", "SavedSearch"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SavedSearchSave adds a saved search to the account if its ID is zero, and
+		// otherwise updates the existing saved search. The saved search is returned with
+		// message counts.
+		async SavedSearchSave(ss) {
+			const fn = "SavedSearchSave";
+			const paramTypes = [["SavedSearch"]];
+			const returnTypes = [["SavedSearch"]];
+			const params = [ss];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SavedSearchRemove removes a saved search from the account.
+		async SavedSearchRemove(savedSearchID) {
+			const fn = "SavedSearchRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [savedSearchID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Drafts returns the autosaved drafts of messages being composed, most recently
+		// saved first, e.g. for continuing after the browser was closed. Drafts that
+		// haven't been saved for 30 days are removed.
+		async Drafts() {
+			const fn = "Drafts";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Draft"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftSave autosaves the compose state of a draft. A new draft is created if ID
+		// is 0. For existing drafts, Version must be the version of the last save, which
+		// is incremented, otherwise the draft was changed from another device or browser
+		// window and error code "user:draftConflict" is returned.
+		async DraftSave(d) {
+			const fn = "DraftSave";
+			const paramTypes = [["Draft"]];
+			const returnTypes = [["Draft"]];
+			const params = [d];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftRemove removes a draft and its attachments.
+		async DraftRemove(draftID) {
+			const fn = "DraftRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [draftID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftAttachmentAdd uploads an attachment for a draft, so it doesn't have to be
+		// uploaded again with each save or when sending.
+		async DraftAttachmentAdd(draftID, f) {
+			const fn = "DraftAttachmentAdd";
+			const paramTypes = [["int64"], ["File"]];
+			const returnTypes = [["DraftAttachment"]];
+			const params = [draftID, f];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftAttachmentRemove removes an uploaded attachment from a draft.
+		async DraftAttachmentRemove(attachmentID) {
+			const fn = "DraftAttachmentRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [attachmentID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelSave adds a label to the end of the list of labels, or updates the color
+		// of an existing label. Messages are not changed.
+		async LabelSave(label) {
+			const fn = "LabelSave";
+			const paramTypes = [["Label"]];
+			const returnTypes = [["Label"]];
+			const params = [label];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelRename renames a label, and the labels below it in the hierarchy. The
+		// keywords of affected messages are changed in the same transaction. If a label
+		// with the new name already exists, messages with the old label get the existing
+		// label. The number of changed messages is returned.
+		async LabelRename(oldName, newName) {
+			const fn = "LabelRename";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [["int32"]];
+			const params = [oldName, newName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelRemove removes a label, and the labels below it in the hierarchy. The
+		// keywords are removed from messages in the same transaction. The number of
+		// changed messages is returned.
+		async LabelRemove(name) {
+			const fn = "LabelRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [["int32"]];
+			const params = [name];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelsOrder sets the display order of labels. Names must be those of all
+		// existing labels.
+		async LabelsOrder(names) {
+			const fn = "LabelsOrder";
+			const paramTypes = [["[]", "string"]];
+			const returnTypes = [];
+			const params = [names];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// WebPushKey returns the public key of the account for Web Push, base64url
+		// encoded, for use as "applicationServerKey" when subscribing in the browser.
+		async WebPushKey() {
+			const fn = "WebPushKey";
+			const paramTypes = [];
+			const returnTypes = [["string"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// WebPushSubscribe adds a Web Push subscription of the browser, or updates the
+		// keys of an existing subscription with the same endpoint. Notifications about
+		// new messages matching the settings are sent to the subscription. The keys are
+		// base64url encoded, as returned by the browser.
+		async WebPushSubscribe(endpoint, p256dh, auth) {
+			const fn = "WebPushSubscribe";
+			const paramTypes = [["string"], ["string"], ["string"]];
+			const returnTypes = [];
+			const params = [endpoint, p256dh, auth];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// WebPushUnsubscribe removes the Web Push subscription with the endpoint.
+		async WebPushUnsubscribe(endpoint) {
+			const fn = "WebPushUnsubscribe";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [endpoint];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Delegations returns the access to mailboxes the account has given to other
+		// accounts, and the access other accounts have given to the account.
+		async Delegations() {
+			const fn = "Delegations";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Delegation"], ["[]", "DelegatedAccess"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegationSave gives another account access to mailboxes, replacing access
+		// given earlier to that account.
+		async DelegationSave(d) {
+			const fn = "DelegationSave";
+			const paramTypes = [["Delegation"]];
+			const returnTypes = [];
+			const params = [d];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegationRemove removes access given to another account.
+		async DelegationRemove(account) {
+			const fn = "DelegationRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [account];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedMessages returns messages from a mailbox of another account that gave
+		// access, most recently received first.
+		async DelegatedMessages(owner, mailbox, offset, limit) {
+			const fn = "DelegatedMessages";
+			const paramTypes = [["string"], ["string"], ["int32"], ["int32"]];
+			const returnTypes = [["[]", "MessageItem"]];
+			const params = [owner, mailbox, offset, limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedParsedMessage returns a message from a mailbox of another account that
+		// gave access.
+		async DelegatedParsedMessage(owner, msgID) {
+			const fn = "DelegatedParsedMessage";
+			const paramTypes = [["string"], ["int64"]];
+			const returnTypes = [["ParsedMessage"]];
+			const params = [owner, msgID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedFlagsAdd adds flags to messages in mailboxes of another account that
+		// gave write access.
+		async DelegatedFlagsAdd(owner, messageIDs, flaglist) {
+			const fn = "DelegatedFlagsAdd";
+			const paramTypes = [["string"], ["[]", "int64"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs, flaglist];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedFlagsClear clears flags of messages in mailboxes of another account
+		// that gave write access.
+		async DelegatedFlagsClear(owner, messageIDs, flaglist) {
+			const fn = "DelegatedFlagsClear";
+			const paramTypes = [["string"], ["[]", "int64"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs, flaglist];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedMessageMove moves messages to another mailbox of the delegation by
+		// another account that gave write access.
+		async DelegatedMessageMove(owner, messageIDs, mailbox) {
+			const fn = "DelegatedMessageMove";
+			const paramTypes = [["string"], ["[]", "int64"], ["string"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs, mailbox];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedMessageDelete permanently deletes messages in mailboxes of another
+		// account that gave write access.
+		async DelegatedMessageDelete(owner, messageIDs) {
+			const fn = "DelegatedMessageDelete";
+			const paramTypes = [["string"], ["[]", "int64"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// SSETypes exists to ensure the generated API contains the types, for use in SSE events.
 		async SSETypes() {
 			const fn = "SSETypes";
 			const paramTypes = [];
-			const returnTypes = [["EventStart"], ["EventViewErr"], ["EventViewReset"], ["EventViewMsgs"], ["EventViewChanges"], ["ChangeMsgAdd"], ["ChangeMsgRemove"], ["ChangeMsgFlags"], ["ChangeMsgThread"], ["ChangeMailboxRemove"], ["ChangeMailboxAdd"], ["ChangeMailboxRename"], ["ChangeMailboxCounts"], ["ChangeMailboxSpecialUse"], ["ChangeMailboxKeywords"], ["Flags"]];
+			const returnTypes = [["EventStart"], ["EventViewErr"], ["EventViewReset"], ["EventViewMsgs"], ["EventViewChanges"], ["ChangeMsgAdd"], ["ChangeMsgRemove"], ["ChangeMsgFlags"], ["ChangeMsgThread"], ["ChangeMailboxRemove"], ["ChangeMailboxAdd"], ["ChangeMailboxRename"], ["ChangeMailboxCounts"], ["ChangeMailboxSpecialUse"], ["ChangeMailboxKeywords"], ["EventSavedSearches"], ["EventBulkProgress"], ["ViewResume"], ["Flags"]];
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
@@ -0,0 +1,219 @@
+package webmail
+
+// Thumbnails of image attachments, and previews of PDF attachments, generated on
+// the server so the webmail doesn't have to download large originals for showing
+// small images. Generated thumbnails are kept in an in-memory cache.
+//
+// PDFs are not rendered, there is no PDF renderer. For scanned documents, the
+// first page typically consists of a JPEG image, which is used as preview.
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/moxio"
+)
+
+// Size variants of thumbnails, the maximum width and height.
+var thumbnailSizes = map[string]int{
+	"small":  64,
+	"medium": 256,
+	"large":  1024,
+}
+
+const (
+	thumbnailMaxSource   = 32 * 1024 * 1024 // Maximum decoded size of attachments to generate thumbnails for.
+	thumbnailMaxPixels   = 50 * 1000 * 1000 // Maximum width*height of source images.
+	thumbnailCacheMax    = 32 * 1024 * 1024 // For all cached thumbnails.
+	thumbnailCacheMaxAge = 24 * time.Hour   // Max-age for HTTP caching, message contents don't change.
+)
+
+var errThumbnailUnsupported = errors.New("no thumbnail for attachment type")
+
+type thumbnailEntry struct {
+	data []byte // JPEG.
+	used time.Time
+}
+
+// Cache of generated thumbnails, keyed by account, message, part and size.
+var thumbnailCache = struct {
+	sync.Mutex
+	entries map[string]thumbnailEntry
+	size    int
+}{entries: map[string]thumbnailEntry{}}
+
+func thumbnailCacheGet(key string) ([]byte, bool) {
+	thumbnailCache.Lock()
+	defer thumbnailCache.Unlock()
+	e, ok := thumbnailCache.entries[key]
+	if ok {
+		e.used = time.Now()
+		thumbnailCache.entries[key] = e
+	}
+	return e.data, ok
+}
+
+func thumbnailCacheAdd(key string, data []byte) {
+	thumbnailCache.Lock()
+	defer thumbnailCache.Unlock()
+	if oe, ok := thumbnailCache.entries[key]; ok {
+		thumbnailCache.size -= len(oe.data)
+	}
+	thumbnailCache.entries[key] = thumbnailEntry{data, time.Now()}
+	thumbnailCache.size += len(data)
+	// Remove least recently used entries until we are within the limit.
+	for thumbnailCache.size > thumbnailCacheMax {
+		var oldest string
+		var oldestUsed time.Time
+		for k, e := range thumbnailCache.entries {
+			if k != key && (oldest == "" || e.used.Before(oldestUsed)) {
+				oldest = k
+				oldestUsed = e.used
+			}
+		}
+		if oldest == "" {
+			break
+		}
+		thumbnailCache.size -= len(thumbnailCache.entries[oldest].data)
+		delete(thumbnailCache.entries, oldest)
+	}
+}
+
+// thumbnail returns a JPEG thumbnail for the image or PDF in part p, scaled to
+// fit within size by size pixels. Images smaller than size are not enlarged.
+// errThumbnailUnsupported is returned for other types of parts.
+func thumbnail(p *message.Part, size int) ([]byte, error) {
+	if p.DecodedSize > thumbnailMaxSource {
+		return nil, fmt.Errorf("%w: attachment too large", errThumbnailUnsupported)
+	}
+	var r io.Reader
+	switch mt := p.MediaType + "/" + p.MediaSubType; mt {
+	case "IMAGE/PNG", "IMAGE/JPEG", "IMAGE/JPG", "IMAGE/GIF":
+		r = p.Reader()
+	case "APPLICATION/PDF":
+		buf, err := io.ReadAll(&moxio.LimitReader{R: p.Reader(), Limit: thumbnailMaxSource})
+		if err != nil {
+			return nil, fmt.Errorf("reading pdf: %w", err)
+		}
+		jpg := pdfFirstJPEG(buf)
+		if jpg == nil {
+			return nil, fmt.Errorf("%w: no image in pdf", errThumbnailUnsupported)
+		}
+		r = bytes.NewReader(jpg)
+	default:
+		return nil, errThumbnailUnsupported
+	}
+
+	// Check dimensions before decoding, to prevent excessive memory use for small
+	// compressed images with large dimensions.
+	var hdr bytes.Buffer
+	cfg, _, err := image.DecodeConfig(io.TeeReader(r, &hdr))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image config: %w", err)
+	} else if cfg.Width <= 0 || cfg.Height <= 0 || int64(cfg.Width)*int64(cfg.Height) > thumbnailMaxPixels {
+		return nil, fmt.Errorf("%w: bad image dimensions %dx%d", errThumbnailUnsupported, cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(io.MultiReader(&hdr, r))
+	if err != nil {
+		return nil, fmt.Errorf("decoding image: %w", err)
+	}
+
+	dst := thumbnailScale(src, size)
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("encoding thumbnail: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// thumbnailScale scales src down to fit within size by size pixels, averaging
+// the source pixels for each destination pixel. Transparent areas become white.
+func thumbnailScale(src image.Image, size int) *image.RGBA {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, max(1, sh*size/sw)
+		} else {
+			dw, dh = max(1, sw*size/sh), size
+		}
+	}
+
+	// Flatten onto white, JPEG has no transparency.
+	flat := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), src, sb.Min, draw.Over)
+	if dw == sw && dh == sh {
+		return flat
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		y0, y1 := y*sh/dh, max((y+1)*sh/dh, y*sh/dh+1)
+		for x := range dw {
+			x0, x1 := x*sw/dw, max((x+1)*sw/dw, x*sw/dw+1)
+			var r, g, b, n int
+			for sy := y0; sy < y1; sy++ {
+				o := flat.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += int(flat.Pix[o])
+					g += int(flat.Pix[o+1])
+					b += int(flat.Pix[o+2])
+					o += 4
+					n++
+				}
+			}
+			o := dst.PixOffset(x, y)
+			dst.Pix[o] = uint8(r / n)
+			dst.Pix[o+1] = uint8(g / n)
+			dst.Pix[o+2] = uint8(b / n)
+			dst.Pix[o+3] = 0xff
+		}
+	}
+	return dst
+}
+
+// pdfFirstJPEG returns the data of the first image object in the PDF that is a
+// JPEG, or nil if there is none. Objects in compressed object streams are not
+// found.
+func pdfFirstJPEG(buf []byte) []byte {
+	s := buf
+	for {
+		i := bytes.Index(s, []byte("/DCTDecode"))
+		if i < 0 {
+			return nil
+		}
+		// The object dictionary must be for an image, and be followed by its stream.
+		objStart := bytes.LastIndex(s[:i], []byte(" obj"))
+		streamStart := bytes.Index(s[i:], []byte("stream"))
+		if objStart < 0 || streamStart < 0 {
+			return nil
+		}
+		streamStart += i
+		dict := s[objStart:streamStart]
+		if !bytes.Contains(dict, []byte("/Image")) || bytes.Contains(dict, []byte("endobj")) {
+			s = s[i+len("/DCTDecode"):]
+			continue
+		}
+		data := s[streamStart+len("stream"):]
+		data = bytes.TrimPrefix(data, []byte("\r"))
+		data = bytes.TrimPrefix(data, []byte("\n"))
+		end := bytes.Index(data, []byte("endstream"))
+		if end < 0 {
+			return nil
+		}
+		return bytes.TrimRight(data[:end], "\r\n")
+	}
+}
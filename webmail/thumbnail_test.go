@@ -0,0 +1,85 @@
+package webmail
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/mox/message"
+)
+
+func TestThumbnail(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 400, 100))
+	for y := range 100 {
+		for x := range 400 {
+			img.Set(x, y, color.NRGBA{uint8(x), 0, uint8(y), 0xff})
+		}
+	}
+	var pngbuf, jpgbuf bytes.Buffer
+	err := png.Encode(&pngbuf, img)
+	tcheck(t, err, "encode png")
+	err = jpeg.Encode(&jpgbuf, img, nil)
+	tcheck(t, err, "encode jpeg")
+
+	pdf := "%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n2 0 obj\n<< /Type /XObject /Subtype /Image /Width 400 /Height 100 /Filter /DCTDecode >>\nstream\n" + jpgbuf.String() + "\nendstream\nendobj\n%%EOF\n"
+
+	part := func(ct string, data []byte) *message.Part {
+		t.Helper()
+		msg := "Content-Type: " + ct + "\r\nContent-Transfer-Encoding: base64\r\n\r\n" + base64.StdEncoding.EncodeToString(data) + "\r\n"
+		p, err := message.Parse(pkglog.Logger, false, strings.NewReader(msg))
+		tcheck(t, err, "parse message")
+		err = p.Walk(pkglog.Logger, nil)
+		tcheck(t, err, "walk message")
+		return &p
+	}
+
+	check := func(p *message.Part, size, expWidth, expHeight int) {
+		t.Helper()
+		data, err := thumbnail(p, size)
+		tcheck(t, err, "thumbnail")
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		tcheck(t, err, "decode thumbnail")
+		tcompare(t, format, "jpeg")
+		tcompare(t, [2]int{cfg.Width, cfg.Height}, [2]int{expWidth, expHeight})
+	}
+
+	check(part("image/png", pngbuf.Bytes()), 64, 64, 16)
+	check(part("image/jpeg", jpgbuf.Bytes()), 256, 256, 64)
+	// Not enlarged.
+	check(part("image/png", pngbuf.Bytes()), 1024, 400, 100)
+	check(part("application/pdf", []byte(pdf)), 64, 64, 16)
+
+	_, err = thumbnail(part("text/plain", []byte("hi")), 64)
+	if !errors.Is(err, errThumbnailUnsupported) {
+		t.Fatalf("got err %v, expected errThumbnailUnsupported", err)
+	}
+	_, err = thumbnail(part("application/pdf", []byte("%PDF-1.4\n%%EOF\n")), 64)
+	if !errors.Is(err, errThumbnailUnsupported) {
+		t.Fatalf("got err %v, expected errThumbnailUnsupported", err)
+	}
+	_, err = thumbnail(part("image/png", []byte("PNG...")), 64)
+	if err == nil {
+		t.Fatalf("expected error for invalid image")
+	}
+
+	// Averaging of pixels, with transparent pixels becoming white.
+	src := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	src.Set(0, 0, color.NRGBA{0, 0, 0, 0xff})
+	dst := thumbnailScale(src, 1)
+	tcompare(t, dst.Bounds(), image.Rect(0, 0, 1, 1))
+	tcompare(t, dst.RGBAAt(0, 0), color.RGBA{0x7f, 0x7f, 0x7f, 0xff})
+
+	thumbnailCacheAdd("a", make([]byte, thumbnailCacheMax))
+	thumbnailCacheAdd("b", []byte("b"))
+	_, ok := thumbnailCacheGet("a")
+	tcompare(t, ok, false)
+	data, ok := thumbnailCacheGet("b")
+	tcompare(t, ok, true)
+	tcompare(t, string(data), "b")
+}
@@ -0,0 +1,131 @@
+package webmail
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mjl-/mox/smtp"
+)
+
+// ListUnsubscribe has the methods from the List-Unsubscribe header of a message,
+// RFC 2369, for unsubscribing from a mailing list.
+type ListUnsubscribe struct {
+	URL    string // HTTP(S) URL, for one-click unsubscribe or for opening in a browser.
+	Mailto string // mailto URI, for sending an unsubscribe message.
+
+	// If URL is HTTPS and List-Unsubscribe-Post requests one-click unsubscribe, RFC
+	// 8058. Only for messages with a valid DKIM signature, as required by the RFC.
+	OneClick bool
+}
+
+// parseListUnsubscribe parses the List-Unsubscribe and List-Unsubscribe-Post
+// headers, returning nil if there is no usable unsubscribe method.
+func parseListUnsubscribe(listUnsubscribe, listUnsubscribePost string, dkimSigned bool) *ListUnsubscribe {
+	/*
+		Examples:
+		List-Unsubscribe: <mailto:list-request@host.com?subject=unsubscribe>
+		List-Unsubscribe: (Use this command to get off the list) <mailto:list-manager@host.com?body=unsubscribe%20list>
+		List-Unsubscribe: <https://host.com/unsubscribe?id=123>, <mailto:unsubscribe@host.com>
+	*/
+	var u ListUnsubscribe
+	s := strings.TrimSpace(listUnsubscribe)
+	for s != "" {
+		// Skip comments.
+		if strings.HasPrefix(s, "(") {
+			_, ns, found := strings.Cut(s, ")")
+			if !found {
+				break
+			}
+			s = strings.TrimSpace(ns)
+			continue
+		}
+		if !strings.HasPrefix(s, "<") {
+			break
+		}
+		uri, ns, found := strings.Cut(s[1:], ">")
+		if !found {
+			break
+		}
+		// Whitespace may have been added when folding the header.
+		uri = strings.Join(strings.Fields(uri), "")
+		lower := strings.ToLower(uri)
+		if strings.HasPrefix(lower, "mailto:") && u.Mailto == "" {
+			u.Mailto = uri
+		} else if (strings.HasPrefix(lower, "https://") || strings.HasPrefix(lower, "http://")) && u.URL == "" {
+			u.URL = uri
+		}
+		s = strings.TrimSpace(ns)
+		s = strings.TrimPrefix(s, ",")
+		s = strings.TrimSpace(s)
+	}
+	if u.URL == "" && u.Mailto == "" {
+		return nil
+	}
+	u.OneClick = dkimSigned && strings.HasPrefix(strings.ToLower(u.URL), "https://") && strings.EqualFold(strings.TrimSpace(listUnsubscribePost), "List-Unsubscribe=One-Click")
+	return &u
+}
+
+// unsubscribeMailto parses a mailto URI from a List-Unsubscribe header,
+// returning the address to send the unsubscribe message to, and the subject
+// and body, with defaults if absent.
+func unsubscribeMailto(uri string) (to smtp.Address, subject, body string, rerr error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return to, "", "", fmt.Errorf("parsing mailto uri: %w", err)
+	} else if !strings.EqualFold(u.Scheme, "mailto") {
+		return to, "", "", fmt.Errorf("not a mailto uri")
+	}
+	addrs, err := url.PathUnescape(u.Opaque)
+	if err != nil {
+		return to, "", "", fmt.Errorf("unescaping address: %w", err)
+	}
+	q := u.Query()
+	if addrs == "" {
+		addrs = q.Get("to")
+	}
+	// Only the first address is used.
+	addr, _, _ := strings.Cut(addrs, ",")
+	to, err = smtp.ParseAddress(strings.TrimSpace(addr))
+	if err != nil {
+		return to, "", "", fmt.Errorf("parsing address: %w", err)
+	}
+	subject = q.Get("subject")
+	if subject == "" {
+		subject = "unsubscribe"
+	}
+	body = q.Get("body")
+	if body == "" {
+		body = "unsubscribe"
+	}
+	return to, subject, body, nil
+}
+
+// unsubscribeOneClick makes an RFC 8058 one-click unsubscribe request. The
+// request is made through the same client as the image proxy, so internal
+// addresses cannot be reached.
+func unsubscribeOneClick(ctx context.Context, u string) error {
+	if !strings.HasPrefix(strings.ToLower(u), "https://") {
+		return fmt.Errorf("one-click unsubscribe requires an https url")
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", u, strings.NewReader("List-Unsubscribe=One-Click"))
+	if err != nil {
+		return err
+	}
+	// No cookies or other context, just generic headers.
+	req.Header.Set("User-Agent", "mox")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := proxyClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("remote responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
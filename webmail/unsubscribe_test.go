@@ -0,0 +1,80 @@
+package webmail
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseListUnsubscribe(t *testing.T) {
+	check := func(hdr, post string, dkim bool, exp *ListUnsubscribe) {
+		t.Helper()
+		tcompare(t, parseListUnsubscribe(hdr, post, dkim), exp)
+	}
+
+	check("", "", true, nil)
+	check("NO", "", true, nil)
+	check("<ftp://host.example/unsubscribe>", "", true, nil)
+	check("<mailto:list-request@host.example?subject=unsubscribe>", "", true, &ListUnsubscribe{Mailto: "mailto:list-request@host.example?subject=unsubscribe"})
+	check("(Use this command to get off the list) <mailto:list-manager@host.example?body=unsubscribe%20list>", "", true, &ListUnsubscribe{Mailto: "mailto:list-manager@host.example?body=unsubscribe%20list"})
+	check("<https://host.example/unsub?id=1>, <mailto:unsub@host.example>", "List-Unsubscribe=One-Click", true, &ListUnsubscribe{URL: "https://host.example/unsub?id=1", Mailto: "mailto:unsub@host.example", OneClick: true})
+	// Without DKIM signature, or without https, no one-click.
+	check("<https://host.example/unsub?id=1>", "List-Unsubscribe=One-Click", false, &ListUnsubscribe{URL: "https://host.example/unsub?id=1"})
+	check("<http://host.example/unsub?id=1>", "List-Unsubscribe=One-Click", true, &ListUnsubscribe{URL: "http://host.example/unsub?id=1"})
+	// Folded header.
+	check("<https://host.example/unsub?\r\n id=1>", "", true, &ListUnsubscribe{URL: "https://host.example/unsub?id=1"})
+}
+
+func TestUnsubscribeMailto(t *testing.T) {
+	to, subject, body, err := unsubscribeMailto("mailto:list-request@host.example?subject=remove%20me")
+	tcheck(t, err, "parse mailto")
+	tcompare(t, to.String(), "list-request@host.example")
+	tcompare(t, subject, "remove me")
+	tcompare(t, body, "unsubscribe")
+
+	to, _, _, err = unsubscribeMailto("mailto:a%2Bb@host.example,other@host.example")
+	tcheck(t, err, "parse mailto")
+	tcompare(t, to.String(), "a+b@host.example")
+
+	_, _, _, err = unsubscribeMailto("https://host.example")
+	if err == nil {
+		t.Fatalf("expected error for non-mailto uri")
+	}
+	_, _, _, err = unsubscribeMailto("mailto:")
+	if err == nil {
+		t.Fatalf("expected error for mailto without address")
+	}
+}
+
+func TestUnsubscribeOneClick(t *testing.T) {
+	var status int
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := io.ReadAll(r.Body)
+		if r.Method != "POST" || string(buf) != "List-Unsubscribe=One-Click" || r.Header.Get("Cookie") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	origClient := proxyClient
+	defer func() { proxyClient = origClient }()
+	proxyClient = srv.Client()
+
+	status = http.StatusOK
+	err := unsubscribeOneClick(ctxbg, srv.URL+"/unsubscribe")
+	tcheck(t, err, "one-click unsubscribe")
+
+	status = http.StatusNotFound
+	err = unsubscribeOneClick(ctxbg, srv.URL+"/unsubscribe")
+	if err == nil {
+		t.Fatalf("expected error for not found")
+	}
+
+	err = unsubscribeOneClick(ctxbg, "http://host.example/unsubscribe")
+	if err == nil {
+		t.Fatalf("expected error for plain http")
+	}
+}
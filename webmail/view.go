@@ -11,6 +11,7 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"net/http"
 	"path/filepath"
 	"reflect"
@@ -24,6 +25,7 @@ import (
 	"github.com/mjl-/bstore"
 	"github.com/mjl-/sherpa"
 
+	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/dns"
 	"github.com/mjl-/mox/message"
 	"github.com/mjl-/mox/metrics"
@@ -48,6 +50,11 @@ type Request struct {
 	// If set, this request and its view are canceled. A new view must be started.
 	Cancel bool
 
+	// If set, other views on the connection are kept, with changes sent for each of
+	// them, e.g. for showing multiple mailboxes at the same time. Otherwise, a request
+	// for a new view closes the other views.
+	KeepViews bool
+
 	Query Query
 	Page  Page
 }
@@ -98,6 +105,7 @@ type Filter struct {
 	MailboxName string
 
 	Words       []string // Case insensitive substring match for each string.
+	WordHashes  []string // For accounts with a hashed search index: Hashes of whole words, see EventStart.SearchIndexSalt.
 	From        []string
 	To          []string // Including Cc and Bcc.
 	Oldest      *time.Time
@@ -113,6 +121,7 @@ type Filter struct {
 // NotFilter matches messages that don't match these fields.
 type NotFilter struct {
 	Words       []string
+	WordHashes  []string
 	From        []string
 	To          []string
 	Subject     []string
@@ -171,8 +180,16 @@ type MessageItem struct {
 	Attachments []Attachment
 	IsSigned    bool
 	IsEncrypted bool
-	MatchQuery  bool        // If message does not match query, it can still be included because of threading.
-	MoreHeaders [][2]string // All headers from store.Settings.ShowHeaders that are present.
+	SMIME       *SMIMEStatus     // For messages with an S/MIME signature.
+	Calendar    *CalendarInvite  // From the first text/calendar part, e.g. a meeting invitation.
+	Unsubscribe *ListUnsubscribe // From List-Unsubscribe header, for mailing lists.
+	Auth        *AuthResults     // From headers added by mox during delivery. Nil for messages not delivered over SMTP.
+	MatchQuery  bool             // If message does not match query, it can still be included because of threading.
+	MoreHeaders [][2]string      // All headers from store.Settings.ShowHeaders that are present.
+
+	// Reasons to be suspicious of the sender, e.g. a domain resembling that of a
+	// contact. Only set for messages in views.
+	SenderWarnings []SenderWarning
 }
 
 // ParsedMessage has more parsed/derived information about a message, intended
@@ -198,11 +215,17 @@ type ParsedMessage struct {
 	TextPaths [][]int // Paths to text parts.
 	HTMLPath  []int   // Path to HTML part.
 
+	SMIMECertificate *SMIMECertificate // Of the signer, for messages with an S/MIME signature.
+	SMIMEPinned      string            // Fingerprint of the S/MIME key pinned for the From address. If it differs from the key that signed this message, the sender switched keys.
+
 	// Information used by MessageItem, not exported in this type.
 	envelope    MessageEnvelope
 	attachments []Attachment
 	isSigned    bool
 	isEncrypted bool
+	smime       *SMIMEStatus
+	calendar    *CalendarInvite
+	unsubscribe *ListUnsubscribe
 }
 
 // EventStart is the first message sent on an SSE connection, giving the client
@@ -217,8 +240,43 @@ type EventStart struct {
 	Mailboxes            []store.Mailbox
 	RejectsMailbox       string
 	Settings             store.Settings
-	AccountPath          string // If nonempty, the path on same host to webaccount interface.
+	Identities           []config.Identity // For composing messages, with From address, display name and signature.
+	PGPAddresses         []string          // Addresses with an OpenPGP private key, for signing and encrypting when composing.
+	Delegated            []DelegatedAccess // Access to mailboxes given by other accounts, for opening their mailboxes and sending messages.
+	SavedSearches        []SavedSearch     // Shown with the mailboxes. Updated counts are sent in EventSavedSearches.
+	AccountPath          string            // If nonempty, the path on same host to webaccount interface.
+	QuotaWarning         string            // If nonempty, the account is near its quota or over its soft limit, shown to the user.
+	Impersonation        *Impersonation    // If set, an admin is acting as the account, shown to the user.
 	Version              string
+
+	// If nonempty, the account has a hashed full-text search index, and this is the
+	// base64-encoded salt. Search words must be sent as WordHashes, the hex-encoded
+	// first 16 bytes of the HMAC-SHA256 keyed with the salt of each lower-cased run
+	// of letters and digits. Runs of more than 64 characters are hashed as parts of 64
+	// characters, starting every 32 characters. Only whole words can be searched for.
+	SearchIndexSalt string
+
+	// Whether the view from the request was resumed. If so, no messages are sent for
+	// the request, only changes since the last event ID from the resume parameter,
+	// in EventViewChanges.
+	Resumed bool
+}
+
+// Impersonation is a session of an admin acting as the account.
+type Impersonation struct {
+	By       string    // Admin, e.g. "(admin)" or "(admin:<user>)", as in the audit log.
+	ReadOnly bool      // If set, no changes can be made.
+	End      time.Time // After which the session cannot be used.
+}
+
+// ViewResume is passed as "resume" parameter by a client reconnecting, to resume
+// its view instead of fetching all messages again. If the changes since the last
+// event cannot be determined, e.g. because history of removed messages has been
+// cleaned up, the request is executed as a new query instead.
+type ViewResume struct {
+	LastEventID         int64     // The "id" of the last event received, the modseq of the account.
+	LastMessageReceived time.Time // Of the last message in the view. New messages after it are out of range.
+	ViewEnd             bool      // Whether the client has all messages of the view.
 }
 
 // DomainAddressConfig has the address (localpart) configuration for a domain, so
@@ -273,12 +331,25 @@ type EventViewReset struct {
 
 // EventViewChanges contain one or more changes relevant for the client, either
 // with new mailbox total/unseen message counts, or messages added/removed/modified
-// (flags) for the current view.
+// (flags) for one of the views. Changes to mailboxes and threads are not specific
+// to a view, and are sent with ViewID 0.
+//
+// Bursts of changes are combined, with only the last flags of a message and the
+// last counts of a mailbox. Events with changes have an SSE "id" field with the
+// modseq of the account, for resuming after reconnecting.
 type EventViewChanges struct {
 	ViewID  int64
 	Changes [][2]any // The first field of [2]any is a string, the second of the Change types below.
 }
 
+// EventBulkProgress is sent while applying an operation to all messages matching
+// a query, see MessageBulk.
+type EventBulkProgress struct {
+	Op    BulkOp
+	Done  int // Number of messages processed.
+	Total int
+}
+
 // ChangeMsgAdd adds a new message and possibly its thread to the view.
 type ChangeMsgAdd struct {
 	store.ChangeAddUID
@@ -370,9 +441,10 @@ var sses = struct {
 
 // sse represents an sse connection.
 type sse struct {
-	ID          int64        // Also returned in EventStart and used in Request to identify the request.
-	AccountName string       // Used to check the authenticated user has access to the SSE connection.
-	Request     chan Request // Goroutine will receive requests from here, coming from API calls.
+	ID          int64                  // Also returned in EventStart and used in Request to identify the request.
+	AccountName string                 // Used to check the authenticated user has access to the SSE connection.
+	Request     chan Request           // Goroutine will receive requests from here, coming from API calls.
+	Bulk        chan EventBulkProgress // Progress of bulk operations from API calls, sent as event.
 }
 
 // called by the goroutine when the connection is closed or breaks.
@@ -385,6 +457,7 @@ func (sse sse) unregister() {
 	for {
 		select {
 		case <-sse.Request:
+		case <-sse.Bulk:
 		default:
 			return
 		}
@@ -395,7 +468,7 @@ func sseRegister(accountName string) sse {
 	sses.Lock()
 	defer sses.Unlock()
 	sses.gen++
-	v := sse{sses.gen, accountName, make(chan Request, 1)}
+	v := sse{sses.gen, accountName, make(chan Request, 1), make(chan EventBulkProgress, 1)}
 	sses.m[v.ID] = v
 	return v
 }
@@ -536,7 +609,8 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 		http.Error(w, "400 - bad request - bad token", http.StatusBadRequest)
 		return
 	}
-	if _, err := store.SessionUse(ctx, log, accName, sessionToken, ""); err != nil {
+	session, err := store.SessionUse(ctx, log, accName, sessionToken, "")
+	if err != nil {
 		http.Error(w, "400 - bad request - bad session token", http.StatusBadRequest)
 		return
 	}
@@ -577,6 +651,17 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 		req.Query.Threading = ThreadOff
 	}
 
+	// Optional state of the view of a reconnecting client, to resume.
+	var resume ViewResume
+	if s := q.Get("resume"); s != "" {
+		dec := json.NewDecoder(strings.NewReader(s))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&resume); err != nil {
+			http.Error(w, "400 - bad request - bad resume query string parameter: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
 	var writer *eventWriter
 
 	metricSSEConnections.Inc()
@@ -697,6 +782,7 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 	}()
 
 	var mbl []store.Mailbox
+	var pgpKeys []store.PGPKey
 	settings := store.Settings{ID: 1}
 
 	// We only take the rlock when getting the tx.
@@ -710,6 +796,9 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 
 		err = qtx.Get(&settings)
 		xcheckf(ctx, err, "get settings")
+
+		pgpKeys, err = bstore.QueryTx[store.PGPKey](qtx).FilterFn(func(k store.PGPKey) bool { return k.PrivateKey != "" }).List()
+		xcheckf(ctx, err, "list pgp keys")
 	})
 
 	// Find the designated mailbox if a mailbox name is set, or there are no filters at all.
@@ -752,8 +841,6 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 		xgatherMailboxIDs(ctx, qtx, mailboxIDs, mailboxPrefixes)
 	}
 
-	// todo: write a last-event-id based on modseq? if last-event-id is present, we would have to send changes to mailboxes, messages, hopefully reducing the amount of data sent.
-
 	sse := sseRegister(acc.Name)
 	defer sse.unregister()
 
@@ -764,61 +851,158 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 		domainAddressConfigs[a.Domain.ASCII] = DomainAddressConfig{dom.LocalpartCatchallSeparatorsEffective, dom.LocalpartCaseSensitive}
 	}
 
+	pgpAddresses := []string{}
+	for _, k := range pgpKeys {
+		pgpAddresses = append(pgpAddresses, k.Addresses...)
+	}
+
+	// Saved searches are sent again when their counts change.
+	savedSearches, err := savedSearchesList(ctx, log, acc, qtx, accConf.RejectsMailbox)
+	xcheckf(ctx, err, "listing saved searches")
+
+	// Events are sent with the modseq of the account as ID. A reconnecting client
+	// can resume its view from there, if we still have the expunged messages since.
+	lastModSeq, err := acc.HighestModSeq(qtx)
+	xcheckf(ctx, err, "get highest modseq")
+	var resumed bool
+	if since := store.ModSeq(resume.LastEventID); since > 0 && since <= lastModSeq {
+		deletedModSeq, err := acc.HighestDeletedModSeq(qtx)
+		xcheckf(ctx, err, "get highest deleted modseq")
+		resumed = deletedModSeq <= since
+	}
+	writer.eventID = lastModSeq
+
+	qs, err := acc.QuotaStatus(qtx)
+	xcheckf(ctx, err, "get quota status")
+
+	searchSalt, err := store.SearchIndexSalt(qtx)
+	xcheckf(ctx, err, "get search index salt")
+	var searchIndexSalt string
+	if searchSalt != nil {
+		searchIndexSalt = base64.StdEncoding.EncodeToString(searchSalt)
+	}
+
 	// Write first event, allowing client to fill its UI with mailboxes.
-	start := EventStart{sse.ID, loginAddress, addresses, domainAddressConfigs, mailbox.Name, mbl, accConf.RejectsMailbox, settings, accountPath, moxvar.Version}
+	start := EventStart{sse.ID, loginAddress, addresses, domainAddressConfigs, mailbox.Name, mbl, accConf.RejectsMailbox, settings, accConf.Identities, pgpAddresses, delegatedAccess(acc.Name), savedSearches, accountPath, qs.Warning(), nil, moxvar.Version, searchIndexSalt, resumed}
+	if session.ImpersonatedBy != "" {
+		start.Impersonation = &Impersonation{session.ImpersonatedBy, session.ReadOnly, session.ImpersonationEnd}
+	}
 	writer.xsendEvent(ctx, log, "start", start)
 
-	// The goroutine doing the querying will send messages on these channels, which
+	// Counts of saved searches are recalculated a little while after changes to
+	// messages, instead of for each change, counting can be expensive.
+	var savedSearchesTimer <-chan time.Time
+
+	// Changes are processed a short while after they are pending, combining bursts
+	// of changes, e.g. flags for many messages set by a client one at a time.
+	var changesTimer <-chan time.Time
+
+	// The goroutines doing the querying will send messages on these channels, which
 	// result in an event being written on the SSE connection.
 	viewMsgsc := make(chan EventViewMsgs)
 	viewErrc := make(chan EventViewErr)
 	viewResetc := make(chan EventViewReset)
-	donec := make(chan int64) // When request is done.
+	donec := make(chan [2]int64) // View and request ID when request is done.
 
-	// Start a view, it determines if we send a change to the client. And start an
-	// implicit query for messages, we'll send the messages to the client which can
-	// fill its ui with messages.
-	v := view{req, time.Time{}, false, matchMailboxes, mailboxIDs, map[int64]struct{}{}}
-	go viewRequestTx(reqctx, log, acc, qtx, v, viewMsgsc, viewErrc, viewResetc, donec)
-	qtx = nil // viewRequestTx closes qtx
+	// Views of this connection, by view ID. Changes are sent for each of them. A view
+	// can have a request for messages in progress.
+	views := map[int64]*sseView{}
 
-	// When canceling a query, we must drain its messages until it says it is done.
-	// Otherwise the sending goroutine would hang indefinitely on a channel send.
-	cancelDrain := func() {
-		if reqctxcancel != nil {
-			// Cancel the goroutine doing the querying.
-			reqctxcancel()
-			reqctx = nil
-			reqctxcancel = nil
-		} else {
-			return
+	// If we stop and queries are in progress, we must drain the channels they send on.
+	defer func() {
+		var busy int
+		for _, sv := range views {
+			if sv.cancel != nil {
+				sv.cancel()
+				busy++
+			}
 		}
-
-		// Drain events until done.
-		for {
+		for busy > 0 {
 			select {
 			case <-viewMsgsc:
 			case <-viewErrc:
 			case <-viewResetc:
 			case <-donec:
-				return
+				busy--
 			}
 		}
+	}()
+
+	xviewMsgs := func(vm EventViewMsgs) {
+		sv := views[vm.ViewID]
+		if sv == nil || vm.RequestID != sv.Request.ID {
+			panic(fmt.Sprintf("received msgs for unknown view,request id %d,%d", vm.ViewID, vm.RequestID))
+		}
+		if vm.ViewEnd {
+			sv.End = true
+		}
+		if len(vm.MessageItems) > 0 {
+			sv.LastMessageReceived = vm.MessageItems[len(vm.MessageItems)-1][0].Message.Received
+		}
+		writer.xsendEvent(ctx, log, "viewMsgs", vm)
+	}
+	xviewReset := func(vr EventViewReset) {
+		sv := views[vr.ViewID]
+		if sv == nil || vr.RequestID != sv.Request.ID {
+			panic(fmt.Sprintf("received reset for unknown view,request id %d,%d", vr.ViewID, vr.RequestID))
+		}
+		writer.xsendEvent(ctx, log, "viewReset", vr)
+	}
+	viewDone := func(ids [2]int64) {
+		sv := views[ids[0]]
+		if sv == nil || ids[1] != sv.Request.ID {
+			panic(fmt.Sprintf("received done for unknown view,request id %d,%d", ids[0], ids[1]))
+		}
+		sv.cancel()
+		sv.cancel = nil
 	}
 
-	// If we stop and a query is in progress, we must drain the channel it will send on.
-	defer cancelDrain()
+	// When canceling a query, we must drain its messages until it says it is done.
+	// Otherwise the sending goroutine would hang indefinitely on a channel send.
+	// Events for other views are handled as usual.
+	cancelDrain := func(sv *sseView) {
+		if sv.cancel == nil {
+			return
+		}
+		sv.cancel()
+		for {
+			select {
+			case vm := <-viewMsgsc:
+				if vm.ViewID != sv.Request.ViewID {
+					xviewMsgs(vm)
+				}
+			case ve := <-viewErrc:
+				// Errors of other views for a canceled connection are handled by the main loop.
+				if ve.ViewID != sv.Request.ViewID && !errors.Is(ve.err, context.Canceled) && !mlog.IsClosed(ve.err) {
+					writer.xsendEvent(ctx, log, "viewErr", ve)
+				}
+			case vr := <-viewResetc:
+				if vr.ViewID != sv.Request.ViewID {
+					xviewReset(vr)
+				}
+			case ids := <-donec:
+				if ids[0] == sv.Request.ViewID {
+					sv.cancel = nil
+					return
+				}
+				viewDone(ids)
+			}
+		}
+	}
 
-	// Changes broadcasted by other connections on this account. If applicable for the
-	// connection/view, we send events.
-	xprocessChanges := func(changes []store.Change) {
-		taggedChanges := [][2]any{}
+	// Changes broadcasted by other connections on this account. If applicable for a
+	// view, we send events. When resuming, changes are made from the database, and tx
+	// is the transaction to use. Otherwise tx is nil and a transaction is started
+	// when needed.
+	xprocessChanges := func(tx *bstore.Tx, changes []store.Change) {
+		var accountChanges [][2]any
+		viewChanges := map[int64][][2]any{}
 
 		newPreviews := map[int64]string{}
 		defer storeNewPreviews(ctx, log, acc, newPreviews)
 
 		// We get a transaction first time we need it.
-		var xtx *bstore.Tx
+		xtx := tx
 		defer func() {
 			if xtx != nil {
 				err := xtx.Rollback()
@@ -855,9 +1039,20 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 			return moreHeaders
 		}
 
+		// Contacts and recipients, for warnings about senders of MessageItems.
+		var senders *knownSenders
+		xknownSenders := func() *knownSenders {
+			err := ensureTx()
+			xcheckf(ctx, err, "transaction")
+
+			senders, err = ensureKnownSenders(xtx, acc, senders)
+			xcheckf(ctx, err, "ensuring known senders")
+			return senders
+		}
+
 		// Return uids that are within range in view. Because the end has been reached, or
 		// because the UID is not after the last message.
-		xchangedUIDs := func(mailboxID int64, uids []store.UID, isRemove bool) (changedUIDs []store.UID) {
+		xchangedUIDs := func(v *view, mailboxID int64, uids []store.UID, isRemove bool) (changedUIDs []store.UID) {
 			uidsAny := make([]any, len(uids))
 			for i, uid := range uids {
 				uidsAny[i] = uid
@@ -879,85 +1074,111 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 			return changedUIDs
 		}
 
-		// Forward changes that are relevant to the current view.
+		// Views in stable order, for sending events.
+		viewIDs := slices.Sorted(maps.Keys(views))
+
+		// Forward changes that are relevant to the views.
 		for _, change := range changes {
+			switch change.(type) {
+			case store.ChangeAddUID, store.ChangeRemoveUIDs, store.ChangeFlags, store.ChangeRemoveMailbox:
+				if savedSearchesTimer == nil {
+					savedSearchesTimer = time.After(2 * time.Second)
+				}
+			}
+			if modseq := change.ChangeModSeq(); modseq > writer.eventID {
+				writer.eventID = modseq
+			}
+
 			switch c := change.(type) {
 			case store.ChangeAddUID:
-				ok, err := v.matches(log, acc, true, 0, c.MailboxID, c.UID, c.Flags, c.Keywords, getmsg)
-				xcheckf(ctx, err, "matching new message against view")
 				m, err := getmsg(0, c.MailboxID, c.UID)
 				xcheckf(ctx, err, "get message")
-				_, thread := v.threadIDs[m.ThreadID]
-				if !ok && !thread {
-					continue
-				}
-
-				state := msgState{acc: acc, log: log, newPreviews: newPreviews}
-				mi, err := messageItem(log, m, &state, xmoreHeaders())
-				state.clear()
-				xcheckf(ctx, err, "make messageitem")
-				mi.MatchQuery = ok
 
-				mil := []MessageItem{mi}
-				if !thread && req.Query.Threading != ThreadOff {
+				var mi MessageItem // Made for the first view that matches.
+				for _, viewID := range viewIDs {
+					v := &views[viewID].view
 					err := ensureTx()
 					xcheckf(ctx, err, "transaction")
-					more, _, err := gatherThread(log, xtx, acc, v, m, 0, false, xmoreHeaders(), newPreviews)
-					xcheckf(ctx, err, "gathering thread messages for id %d, thread %d", m.ID, m.ThreadID)
-					mil = append(mil, more...)
-					v.threadIDs[m.ThreadID] = struct{}{}
-				}
+					ok, err := v.matches(log, acc, xtx, true, 0, c.MailboxID, c.UID, c.Flags, c.Keywords, getmsg)
+					xcheckf(ctx, err, "matching new message against view")
+					_, thread := v.threadIDs[m.ThreadID]
+					if !ok && !thread {
+						continue
+					}
 
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMsgAdd", ChangeMsgAdd{c, mil}})
+					if mi.Message.ID == 0 {
+						state := msgState{acc: acc, log: log, newPreviews: newPreviews}
+						mi, err = messageItem(log, m, &state, xmoreHeaders())
+						state.clear()
+						xcheckf(ctx, err, "make messageitem")
+						mi.SenderWarnings = senderWarnings(log, xknownSenders(), mi)
+					}
+					vmi := mi
+					vmi.MatchQuery = ok
+
+					mil := []MessageItem{vmi}
+					if !thread && v.Request.Query.Threading != ThreadOff {
+						err := ensureTx()
+						xcheckf(ctx, err, "transaction")
+						more, _, err := gatherThread(log, xtx, acc, *v, m, 0, false, xmoreHeaders(), xknownSenders(), newPreviews)
+						xcheckf(ctx, err, "gathering thread messages for id %d, thread %d", m.ID, m.ThreadID)
+						mil = append(mil, more...)
+						v.threadIDs[m.ThreadID] = struct{}{}
+					}
+
+					viewChanges[viewID] = append(viewChanges[viewID], [2]any{"ChangeMsgAdd", ChangeMsgAdd{c, mil}})
 
-				// If message extends the view, store it as such.
-				if !v.Request.Query.OrderAsc && m.Received.Before(v.LastMessageReceived) || v.Request.Query.OrderAsc && m.Received.After(v.LastMessageReceived) {
-					v.LastMessageReceived = m.Received
+					// If message extends the view, store it as such.
+					if !v.Request.Query.OrderAsc && m.Received.Before(v.LastMessageReceived) || v.Request.Query.OrderAsc && m.Received.After(v.LastMessageReceived) {
+						v.LastMessageReceived = m.Received
+					}
 				}
 
 			case store.ChangeRemoveUIDs:
-				comm.RemovalSeen(c)
-
 				// We may send changes for uids the client doesn't know, that's fine.
-				changedUIDs := xchangedUIDs(c.MailboxID, c.UIDs, true)
-				if len(changedUIDs) == 0 {
-					continue
+				for _, viewID := range viewIDs {
+					changedUIDs := xchangedUIDs(&views[viewID].view, c.MailboxID, c.UIDs, true)
+					if len(changedUIDs) == 0 {
+						continue
+					}
+					ch := ChangeMsgRemove{c}
+					ch.UIDs = changedUIDs
+					viewChanges[viewID] = append(viewChanges[viewID], [2]any{"ChangeMsgRemove", ch})
 				}
-				ch := ChangeMsgRemove{c}
-				ch.UIDs = changedUIDs
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMsgRemove", ch})
 
 			case store.ChangeFlags:
 				// We may send changes for uids the client doesn't know, that's fine.
-				changedUIDs := xchangedUIDs(c.MailboxID, []store.UID{c.UID}, false)
-				if len(changedUIDs) == 0 {
-					continue
+				for _, viewID := range viewIDs {
+					changedUIDs := xchangedUIDs(&views[viewID].view, c.MailboxID, []store.UID{c.UID}, false)
+					if len(changedUIDs) == 0 {
+						continue
+					}
+					ch := ChangeMsgFlags{c}
+					ch.UID = changedUIDs[0]
+					viewChanges[viewID] = append(viewChanges[viewID], [2]any{"ChangeMsgFlags", ch})
 				}
-				ch := ChangeMsgFlags{c}
-				ch.UID = changedUIDs[0]
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMsgFlags", ch})
 
 			case store.ChangeThread:
 				// Change in muted/collaped state, just always ship it.
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMsgThread", ChangeMsgThread{c}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMsgThread", ChangeMsgThread{c}})
 
 			case store.ChangeRemoveMailbox:
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMailboxRemove", ChangeMailboxRemove{c}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMailboxRemove", ChangeMailboxRemove{c}})
 
 			case store.ChangeAddMailbox:
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMailboxAdd", ChangeMailboxAdd{c.Mailbox}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMailboxAdd", ChangeMailboxAdd{c.Mailbox}})
 
 			case store.ChangeRenameMailbox:
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMailboxRename", ChangeMailboxRename{c}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMailboxRename", ChangeMailboxRename{c}})
 
 			case store.ChangeMailboxCounts:
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMailboxCounts", ChangeMailboxCounts{c}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMailboxCounts", ChangeMailboxCounts{c}})
 
 			case store.ChangeMailboxSpecialUse:
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMailboxSpecialUse", ChangeMailboxSpecialUse{c}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMailboxSpecialUse", ChangeMailboxSpecialUse{c}})
 
 			case store.ChangeMailboxKeywords:
-				taggedChanges = append(taggedChanges, [2]any{"ChangeMailboxKeywords", ChangeMailboxKeywords{c}})
+				accountChanges = append(accountChanges, [2]any{"ChangeMailboxKeywords", ChangeMailboxKeywords{c}})
 
 			case store.ChangeAddSubscription, store.ChangeRemoveSubscription:
 				// Webmail does not care about subscriptions.
@@ -970,12 +1191,53 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 			}
 		}
 
-		if len(taggedChanges) > 0 {
-			viewChanges := EventViewChanges{v.Request.ViewID, taggedChanges}
-			writer.xsendEvent(ctx, log, "viewChanges", viewChanges)
+		// The event ID with the new modseq is only sent with the last event, so a client
+		// that disconnects halfway does not skip changes when resuming.
+		eventID := writer.eventID
+		writer.eventID = writer.sentEventID
+		var events []EventViewChanges
+		if len(accountChanges) > 0 {
+			events = append(events, EventViewChanges{0, accountChanges})
+		}
+		for _, viewID := range viewIDs {
+			if l := viewChanges[viewID]; len(l) > 0 {
+				events = append(events, EventViewChanges{viewID, l})
+			}
+		}
+		for i, ev := range events {
+			if i == len(events)-1 {
+				writer.eventID = eventID
+			}
+			writer.xsendEvent(ctx, log, "viewChanges", ev)
+		}
+		if len(events) == 0 {
+			writer.eventID = eventID
 		}
 	}
 
+	// Start a view, it determines if we send a change to the client. And start an
+	// implicit query for messages, we'll send the messages to the client which can
+	// fill its ui with messages. When resuming, we send the changes since the last
+	// event the client received instead.
+	sv := &sseView{view{req, time.Time{}, false, matchMailboxes, mailboxIDs, map[int64]struct{}{}}, nil}
+	views[req.ViewID] = sv
+	if resumed {
+		sv.LastMessageReceived = resume.LastMessageReceived
+		sv.End = resume.ViewEnd
+		changes, err := changesSince(qtx, store.ModSeq(resume.LastEventID))
+		xcheckf(ctx, err, "gathering changes since last event")
+		tx := qtx
+		qtx = nil // xprocessChanges closes tx.
+		xprocessChanges(tx, changes)
+		reqctxcancel()
+	} else {
+		sv.cancel = reqctxcancel
+		go viewRequestTx(reqctx, log, acc, qtx, sv.view, viewMsgsc, viewErrc, viewResetc, donec)
+		qtx = nil // viewRequestTx closes qtx
+	}
+	reqctx = nil
+	reqctxcancel = nil
+
 	timer := time.NewTimer(5 * time.Minute) // For keepalives.
 	defer timer.Stop()
 	for {
@@ -984,18 +1246,24 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 			writer.wrote = false
 		}
 
+		// Changes are only processed when no queries are in progress, the views must be
+		// consistent with the messages sent.
 		pending := comm.Pending
-		if reqctx != nil {
+		changesc := changesTimer
+		if changesTimer != nil {
 			pending = nil
 		}
+		for _, sv := range views {
+			if sv.cancel != nil {
+				pending = nil
+				changesc = nil
+				break
+			}
+		}
 
 		select {
 		case <-mox.Shutdown.Done():
 			writer.xsendEvent(ctx, log, "fatalErr", "server is shutting down")
-			// Work around go vet, it doesn't see defer cancelDrain.
-			if reqctxcancel != nil {
-				reqctxcancel()
-			}
 			return
 
 		case <-timer.C:
@@ -1005,65 +1273,47 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 			}
 			if err != nil {
 				log.Errorx("write keepalive", err)
-				// Work around go vet, it doesn't see defer cancelDrain.
-				if reqctxcancel != nil {
-					reqctxcancel()
-				}
 				return
 			}
 			writer.wrote = true
 
 		case vm := <-viewMsgsc:
-			if vm.RequestID != v.Request.ID || vm.ViewID != v.Request.ViewID {
-				panic(fmt.Sprintf("received msgs for view,request id %d,%d instead of %d,%d", vm.ViewID, vm.RequestID, v.Request.ViewID, v.Request.ID))
-			}
-			if vm.ViewEnd {
-				v.End = true
-			}
-			if len(vm.MessageItems) > 0 {
-				v.LastMessageReceived = vm.MessageItems[len(vm.MessageItems)-1][0].Message.Received
-			}
-			writer.xsendEvent(ctx, log, "viewMsgs", vm)
+			xviewMsgs(vm)
 
 		case ve := <-viewErrc:
-			if ve.RequestID != v.Request.ID || ve.ViewID != v.Request.ViewID {
-				panic(fmt.Sprintf("received err for view,request id %d,%d instead of %d,%d", ve.ViewID, ve.RequestID, v.Request.ViewID, v.Request.ID))
+			sv := views[ve.ViewID]
+			if sv == nil || ve.RequestID != sv.Request.ID {
+				panic(fmt.Sprintf("received err for unknown view,request id %d,%d", ve.ViewID, ve.RequestID))
 			}
 			if errors.Is(ve.err, context.Canceled) || mlog.IsClosed(ve.err) {
-				// Work around go vet, it doesn't see defer cancelDrain.
-				if reqctxcancel != nil {
-					reqctxcancel()
-				}
 				return
 			}
 			writer.xsendEvent(ctx, log, "viewErr", ve)
 
 		case vr := <-viewResetc:
-			if vr.RequestID != v.Request.ID || vr.ViewID != v.Request.ViewID {
-				panic(fmt.Sprintf("received reset for view,request id %d,%d instead of %d,%d", vr.ViewID, vr.RequestID, v.Request.ViewID, v.Request.ID))
-			}
-			writer.xsendEvent(ctx, log, "viewReset", vr)
+			xviewReset(vr)
 
-		case id := <-donec:
-			if id != v.Request.ID {
-				panic(fmt.Sprintf("received done for request id %d instead of %d", id, v.Request.ID))
-			}
-			if reqctxcancel != nil {
-				reqctxcancel()
-			}
-			reqctx = nil
-			reqctxcancel = nil
+		case ids := <-donec:
+			viewDone(ids)
 
 		case req := <-sse.Request:
-			if reqctx != nil {
-				cancelDrain()
+			if sv := views[req.ViewID]; sv != nil {
+				cancelDrain(sv)
 			}
 			if req.Cancel {
-				v = view{req, time.Time{}, false, false, nil, nil}
+				delete(views, req.ViewID)
 				continue
 			}
+			if !req.KeepViews {
+				for viewID, sv := range views {
+					if viewID != req.ViewID {
+						cancelDrain(sv)
+						delete(views, viewID)
+					}
+				}
+			}
 
-			reqctx, reqctxcancel = context.WithCancel(ctx)
+			reqctx, reqctxcancel := context.WithCancel(ctx)
 
 			stop := func() (stop bool) {
 				// rtx is handed off viewRequestTx below, but we must clean it up in case of errors.
@@ -1080,29 +1330,30 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 				})
 				if err != nil {
 					reqctxcancel()
-					reqctx = nil
-					reqctxcancel = nil
 
 					if errors.Is(err, context.Canceled) {
 						return true
 					}
 					err := fmt.Errorf("begin transaction: %v", err)
-					viewErr := EventViewErr{v.Request.ViewID, v.Request.ID, err.Error(), err}
+					viewErr := EventViewErr{req.ViewID, req.ID, err.Error(), err}
 					writer.xsendEvent(ctx, log, "viewErr", viewErr)
 					return false
 				}
 
 				// Reset view state for new query.
-				if req.ViewID != v.Request.ViewID {
+				sv := views[req.ViewID]
+				if sv == nil {
 					matchMailboxes, mailboxIDs, mailboxPrefixes := xprepareMailboxIDs(ctx, rtx, req.Query.Filter, accConf.RejectsMailbox)
 					if req.Query.Filter.MailboxChildrenIncluded {
 						xgatherMailboxIDs(ctx, rtx, mailboxIDs, mailboxPrefixes)
 					}
-					v = view{req, time.Time{}, false, matchMailboxes, mailboxIDs, map[int64]struct{}{}}
+					sv = &sseView{view{req, time.Time{}, false, matchMailboxes, mailboxIDs, map[int64]struct{}{}}, nil}
+					views[req.ViewID] = sv
 				} else {
-					v.Request = req
+					sv.Request = req
 				}
-				go viewRequestTx(reqctx, log, acc, rtx, v, viewMsgsc, viewErrc, viewResetc, donec)
+				sv.cancel = reqctxcancel
+				go viewRequestTx(reqctx, log, acc, rtx, sv.view, viewMsgsc, viewErrc, viewResetc, donec)
 				rtx = nil
 				return false
 			}()
@@ -1110,24 +1361,131 @@ func serveEvents(ctx context.Context, log mlog.Log, accountPath string, w http.R
 				return
 			}
 
+		case bp := <-sse.Bulk:
+			writer.xsendEvent(ctx, log, "bulkProgress", bp)
+
+		case <-savedSearchesTimer:
+			savedSearchesTimer = nil
+			var nsavedSearches []SavedSearch
+			xdbread(ctx, acc, func(tx *bstore.Tx) {
+				nsavedSearches, err = savedSearchesList(ctx, log, acc, tx, accConf.RejectsMailbox)
+				xcheckf(ctx, err, "listing saved searches")
+			})
+			if !reflect.DeepEqual(nsavedSearches, savedSearches) {
+				savedSearches = nsavedSearches
+				writer.xsendEvent(ctx, log, "savedSearches", EventSavedSearches{savedSearches})
+			}
+
 		case <-pending:
+			changesTimer = time.After(changesDelay)
+
+		case <-changesc:
+			changesTimer = nil
 			overflow, changes := comm.Get()
+			// Removals are marked as seen before processing. Otherwise references to the
+			// removed messages, and thus to the account, would be kept when we stop
+			// halfway, e.g. because the connection is gone. We don't read the files of
+			// removed messages.
+			for _, c := range changes {
+				if rem, ok := c.(store.ChangeRemoveUIDs); ok {
+					comm.RemovalSeen(rem)
+				}
+			}
 			if overflow {
 				writer.xsendEvent(ctx, log, "fatalErr", "out of sync, too many pending changes")
 				return
 			}
-			xprocessChanges(changes)
+			xprocessChanges(nil, coalesceChanges(changes))
 
 		case <-ctx.Done():
-			// Work around go vet, it doesn't see defer cancelDrain.
-			if reqctxcancel != nil {
-				reqctxcancel()
-			}
 			return
 		}
 	}
 }
 
+// Delay before processing pending changes, for combining bursts of changes.
+var changesDelay = 100 * time.Millisecond
+
+// sseView is a view of an SSE connection.
+type sseView struct {
+	view
+	cancel context.CancelFunc // Set while a request for messages is in progress.
+}
+
+// coalesceChanges combines bursts of changes: Only the last flags change for a
+// message and the last counts and keywords for a mailbox are kept, at the position
+// of the last change. Masks of flag changes are combined.
+func coalesceChanges(changes []store.Change) []store.Change {
+	type msgKey struct {
+		mailboxID int64
+		uid       store.UID
+	}
+	lastFlags := map[msgKey]int{}
+	lastCounts := map[int64]int{}
+	lastKeywords := map[int64]int{}
+	for i, c := range changes {
+		switch c := c.(type) {
+		case store.ChangeFlags:
+			lastFlags[msgKey{c.MailboxID, c.UID}] = i
+		case store.ChangeMailboxCounts:
+			lastCounts[c.MailboxID] = i
+		case store.ChangeMailboxKeywords:
+			lastKeywords[c.MailboxID] = i
+		}
+	}
+	if len(lastFlags)+len(lastCounts)+len(lastKeywords) == 0 {
+		return changes
+	}
+
+	masks := map[msgKey]store.Flags{}
+	l := make([]store.Change, 0, len(changes))
+	for i, c := range changes {
+		switch c := c.(type) {
+		case store.ChangeFlags:
+			k := msgKey{c.MailboxID, c.UID}
+			masks[k] = masks[k].Set(c.Mask, store.FlagsAll)
+			if lastFlags[k] != i {
+				continue
+			}
+			c.Mask = masks[k]
+			l = append(l, c)
+			continue
+		case store.ChangeMailboxCounts:
+			if lastCounts[c.MailboxID] != i {
+				continue
+			}
+		case store.ChangeMailboxKeywords:
+			if lastKeywords[c.MailboxID] != i {
+				continue
+			}
+		}
+		l = append(l, c)
+	}
+	return l
+}
+
+// changesSince returns changes for messages modified after modseq since, for
+// sending to a client that resumes its view after reconnecting.
+func changesSince(tx *bstore.Tx, since store.ModSeq) ([]store.Change, error) {
+	var changes []store.Change
+	q := bstore.QueryTx[store.Message](tx)
+	q.FilterGreater("ModSeq", since)
+	q.SortAsc("ModSeq")
+	err := q.ForEach(func(m store.Message) error {
+		if m.Expunged {
+			if m.CreateSeq <= since {
+				changes = append(changes, store.ChangeRemoveUIDs{MailboxID: m.MailboxID, UIDs: []store.UID{m.UID}, ModSeq: m.ModSeq, MsgIDs: []int64{m.ID}})
+			}
+		} else if m.CreateSeq > since {
+			changes = append(changes, store.ChangeAddUID{MailboxID: m.MailboxID, UID: m.UID, ModSeq: m.ModSeq, Flags: m.Flags, Keywords: m.Keywords})
+		} else {
+			changes = append(changes, store.ChangeFlags{MailboxID: m.MailboxID, UID: m.UID, ModSeq: m.ModSeq, Mask: store.FlagsAll, Flags: m.Flags, Keywords: m.Keywords})
+		}
+		return nil
+	})
+	return changes, err
+}
+
 // xprepareMailboxIDs prepare the first half of filters for mailboxes, based on
 // f.MailboxID (-1 is special). matchMailboxes indicates whether the IDs in
 // mailboxIDs must or must not match. mailboxPrefixes is for use with
@@ -1174,6 +1532,62 @@ func xgatherMailboxIDs(ctx context.Context, tx *bstore.Tx, mailboxIDs map[int64]
 	xcheckf(ctx, err, "gathering mailboxes")
 }
 
+// matchingMessages calls fn for each non-expunged message that matches the
+// filters of query q, in no particular order. If the mailbox in the filter does
+// not exist (anymore), no messages match.
+func matchingMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bstore.Tx, rejectsMailbox string, q Query, fn func(m store.Message) error) (rerr error) {
+	if q.Filter.MailboxID > 0 {
+		_, err := store.MailboxID(tx, q.Filter.MailboxID)
+		if errors.Is(err, store.ErrMailboxExpunged) || err == bstore.ErrAbsent {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("get mailbox: %v", err)
+		}
+	}
+
+	// The helpers for preparing a view panic on errors.
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(error); ok {
+			rerr = err
+			return
+		}
+		panic(x)
+	}()
+
+	v := view{Request: Request{Query: q}}
+	var mailboxPrefixes []string
+	v.matchMailboxIDs, v.mailboxIDs, mailboxPrefixes = xprepareMailboxIDs(ctx, tx, q.Filter, rejectsMailbox)
+	if q.Filter.MailboxChildrenIncluded {
+		xgatherMailboxIDs(ctx, tx, v.mailboxIDs, mailboxPrefixes)
+	}
+
+	mq := bstore.QueryTx[store.Message](tx)
+	mq.FilterEqual("Expunged", false)
+	if v.matchMailboxIDs && len(v.mailboxIDs) > 0 {
+		ids := make([]any, 0, len(v.mailboxIDs))
+		for id := range v.mailboxIDs {
+			ids = append(ids, id)
+		}
+		mq.FilterEqual("MailboxID", ids...)
+	}
+	return mq.ForEach(func(m store.Message) error {
+		getmsg := func(int64, int64, store.UID) (store.Message, error) {
+			return m, nil
+		}
+		match, err := v.matches(log, acc, tx, false, m.ID, m.MailboxID, m.UID, m.Flags, m.Keywords, getmsg)
+		if err != nil {
+			return fmt.Errorf("matching message: %v", err)
+		} else if match {
+			return fn(m)
+		}
+		return nil
+	})
+}
+
 // matchesMailbox returns whether a mailbox matches the view.
 func (v view) matchesMailbox(mailboxID int64) bool {
 	return len(v.mailboxIDs) == 0 || v.matchMailboxIDs && v.mailboxIDs[mailboxID] || !v.matchMailboxIDs && !v.mailboxIDs[mailboxID]
@@ -1190,8 +1604,9 @@ func (v view) inRange(m store.Message) bool {
 // also if within the range of sent messages based on sort order and the last seen
 // message). getmsg retrieves the message, which may be necessary depending on the
 // active filters. Used to determine if a store.Change with a new message should be
-// sent, and for the destination and anchor messages in view requests.
-func (v view) matches(log mlog.Log, acc *store.Account, checkRange bool, messageID int64, mailboxID int64, uid store.UID, flags store.Flags, keywords []string, getmsg func(int64, int64, store.UID) (store.Message, error)) (match bool, rerr error) {
+// sent, and for the destination and anchor messages in view requests. Tx is used
+// for looking up hashed words in the search index.
+func (v view) matches(log mlog.Log, acc *store.Account, tx *bstore.Tx, checkRange bool, messageID int64, mailboxID int64, uid store.UID, flags store.Flags, keywords []string, getmsg func(int64, int64, store.UID) (store.Message, error)) (match bool, rerr error) {
 	var m store.Message
 	ensureMessage := func() bool {
 		if m.ID == 0 && rerr == nil {
@@ -1256,6 +1671,17 @@ func (v view) matches(log mlog.Log, acc *store.Account, checkRange bool, message
 		return false, rerr
 	}
 
+	if len(q.Filter.WordHashes) > 0 || len(q.NotFilter.WordHashes) > 0 {
+		if !ensureMessage() {
+			return false, rerr
+		}
+		if ok, err := store.SearchIndexHashedMessage(tx, m.ID, q.Filter.WordHashes, q.NotFilter.WordHashes); err != nil {
+			return false, fmt.Errorf("looking up hashed words in search index: %v", err)
+		} else if !ok {
+			return false, nil
+		}
+	}
+
 	// Now check that we are either within the sorting order, or "last" was sent.
 	if !checkRange || v.End || ensureMessage() && v.inRange(m) {
 		return true, rerr
@@ -1307,7 +1733,7 @@ func storeNewPreviews(ctx context.Context, log mlog.Log, acc *store.Account, new
 // and sending Event* to the SSE connection.
 //
 // It always closes tx.
-func viewRequestTx(ctx context.Context, log mlog.Log, acc *store.Account, tx *bstore.Tx, v view, msgc chan EventViewMsgs, errc chan EventViewErr, resetc chan EventViewReset, donec chan int64) {
+func viewRequestTx(ctx context.Context, log mlog.Log, acc *store.Account, tx *bstore.Tx, v view, msgc chan EventViewMsgs, errc chan EventViewErr, resetc chan EventViewReset, donec chan [2]int64) {
 	// Newly generated previews which we'll save when the operation is done.
 	newPreviews := map[int64]string{}
 
@@ -1315,7 +1741,7 @@ func viewRequestTx(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 		err := tx.Rollback()
 		log.Check(err, "rolling back query transaction")
 
-		donec <- v.Request.ID
+		donec <- [2]int64{v.Request.ViewID, v.Request.ID}
 
 		// ctx can be canceled, we still want to store the previews.
 		storeNewPreviews(context.Background(), log, acc, newPreviews)
@@ -1428,7 +1854,7 @@ func queryMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 		} else if err != nil {
 			return false, err
 		} else {
-			return v.matches(log, acc, false, m.ID, m.MailboxID, m.UID, m.Flags, m.Keywords, func(int64, int64, store.UID) (store.Message, error) {
+			return v.matches(log, acc, tx, false, m.ID, m.MailboxID, m.UID, m.Flags, m.Keywords, func(int64, int64, store.UID) (store.Message, error) {
 				return m, nil
 			})
 		}
@@ -1521,6 +1947,31 @@ func queryMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 		q.FilterLessEqual("Size", query.Filter.SizeMax)
 	}
 
+	// Use the full-text search index to skip messages that cannot match the words,
+	// before filters below read message files.
+	if len(query.Filter.Words) > 0 {
+		ids, ok, err := store.SearchIndexCandidates(tx, query.Filter.Words)
+		if err != nil {
+			mrc <- msgResp{err: fmt.Errorf("looking up words in search index: %v", err)}
+			return
+		} else if ok {
+			q.FilterFn(func(m store.Message) bool {
+				_, ok := ids[m.ID]
+				return ok
+			})
+		}
+	}
+	if len(query.Filter.WordHashes) > 0 || len(query.NotFilter.WordHashes) > 0 {
+		match, err := store.SearchIndexHashedMatch(tx, query.Filter.WordHashes, query.NotFilter.WordHashes)
+		if err != nil {
+			mrc <- msgResp{err: fmt.Errorf("looking up hashed words in search index: %v", err)}
+			return
+		}
+		q.FilterFn(func(m store.Message) bool {
+			return match(m.ID)
+		})
+	}
+
 	attachmentFilter := query.attachmentFilterFn(log, acc, &state)
 	if attachmentFilter != nil {
 		q.FilterFn(attachmentFilter)
@@ -1542,6 +1993,7 @@ func queryMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 	}
 
 	var moreHeaders []string // From store.Settings.ShowHeaders
+	var senders *knownSenders
 
 	if query.OrderAsc {
 		q.SortAsc("Received")
@@ -1590,13 +2042,19 @@ func queryMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 			return fmt.Errorf("ensuring more headers: %v", err)
 		}
 
+		senders, err = ensureKnownSenders(tx, acc, senders)
+		if err != nil {
+			return fmt.Errorf("ensuring known senders: %v", err)
+		}
+
 		mi, err := messageItem(log, m, &state, moreHeaders)
 		if err != nil {
 			return fmt.Errorf("making messageitem for message %d: %v", m.ID, err)
 		}
+		mi.SenderWarnings = senderWarnings(log, senders, mi)
 		mil := []MessageItem{mi}
 		if query.Threading != ThreadOff {
-			more, xpm, err := gatherThread(log, tx, acc, v, m, page.DestMessageID, page.AnchorMessageID == 0 && have == 0, moreHeaders, state.newPreviews)
+			more, xpm, err := gatherThread(log, tx, acc, v, m, page.DestMessageID, page.AnchorMessageID == 0 && have == 0, moreHeaders, senders, state.newPreviews)
 			if err != nil {
 				return fmt.Errorf("gathering thread messages for id %d, thread %d: %v", m.ID, m.ThreadID, err)
 			}
@@ -1647,6 +2105,10 @@ func queryMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 			if err != nil {
 				return fmt.Errorf("gathering view mode for id %d: %v", m.ID, err)
 			}
+			pm.SMIMEPinned, err = fromAddrSMIMEPin(tx, pm.envelope.From[0])
+			if err != nil {
+				return fmt.Errorf("gathering s/mime pin for id %d: %v", m.ID, err)
+			}
 		}
 		mrc <- msgResp{mil: mil, pm: pm}
 		return nil
@@ -1665,7 +2127,7 @@ func queryMessages(ctx context.Context, log mlog.Log, acc *store.Account, tx *bs
 	}
 }
 
-func gatherThread(log mlog.Log, tx *bstore.Tx, acc *store.Account, v view, m store.Message, destMessageID int64, first bool, moreHeaders []string, newPreviews map[int64]string) ([]MessageItem, *ParsedMessage, error) {
+func gatherThread(log mlog.Log, tx *bstore.Tx, acc *store.Account, v view, m store.Message, destMessageID int64, first bool, moreHeaders []string, senders *knownSenders, newPreviews map[int64]string) ([]MessageItem, *ParsedMessage, error) {
 	if m.ThreadID == 0 {
 		// If we would continue, FilterNonzero would fail because there are no non-zero fields.
 		return nil, nil, fmt.Errorf("message has threadid 0, account is probably still being upgraded, try turning threading off until the upgrade is done")
@@ -1694,7 +2156,8 @@ func gatherThread(log mlog.Log, tx *bstore.Tx, acc *store.Account, v view, m sto
 			if err != nil {
 				return fmt.Errorf("making messageitem for message %d, for thread %d: %v", tm.ID, m.ThreadID, err)
 			}
-			mi.MatchQuery, err = v.matches(log, acc, false, tm.ID, tm.MailboxID, tm.UID, tm.Flags, tm.Keywords, func(int64, int64, store.UID) (store.Message, error) {
+			mi.SenderWarnings = senderWarnings(log, senders, mi)
+			mi.MatchQuery, err = v.matches(log, acc, tx, false, tm.ID, tm.MailboxID, tm.UID, tm.Flags, tm.Keywords, func(int64, int64, store.UID) (store.Message, error) {
 				return tm, nil
 			})
 			if err != nil {
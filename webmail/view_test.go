@@ -5,8 +5,10 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -14,6 +16,8 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -59,7 +63,7 @@ func TestView(t *testing.T) {
 	loginCookie.Value = api.LoginPrep(ctx)
 	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
 
-	api.Login(ctx, loginCookie.Value, "mjl@mox.example", "test1234")
+	api.Login(ctx, loginCookie.Value, "mjl@mox.example", "test1234", "")
 	var sessionCookie *http.Cookie
 	for _, c := range respRec.Result().Cookies() {
 		if c.Name == "webmailsession" {
@@ -152,7 +156,7 @@ func TestView(t *testing.T) {
 		t.Fatalf("got statuscode %d, expected %d (%s)", resp.StatusCode, http.StatusOK, readBody(resp.Body))
 	}
 
-	evr := eventReader{t, bufio.NewReader(resp.Body), resp.Body}
+	evr := eventReader{t, bufio.NewReader(resp.Body), resp.Body, new(int64)}
 	var start EventStart
 	evr.Get("start", &start)
 	var viewMsgs EventViewMsgs
@@ -191,7 +195,7 @@ func TestView(t *testing.T) {
 			t.Fatalf("got statuscode %d, expected %d", resp.StatusCode, http.StatusOK)
 		}
 
-		xevr := eventReader{t, bufio.NewReader(resp.Body), resp.Body}
+		xevr := eventReader{t, bufio.NewReader(resp.Body), resp.Body, new(int64)}
 		var xstart EventStart
 		xevr.Get("start", &xstart)
 		check(start, xevr)
@@ -350,16 +354,21 @@ func TestView(t *testing.T) {
 	testFilter(false, Filter{Attachments: AttachmentImage}, znf, []int64{inboxFlags.ID})
 	testFilter(false, Filter{MailboxID: inbox.ID}, NotFilter{Attachments: AttachmentImage}, []int64{inboxAltReply.ID, inboxMinimal.ID})
 
-	// Test changes.
+	// Test changes. Changes to mailboxes and threads come first, in an event for view
+	// ID 0, followed by an event with changes for the view.
 	getChanges := func(changes ...any) {
 		t.Helper()
-		var viewChanges EventViewChanges
-		evr.Get("viewChanges", &viewChanges)
-		if len(viewChanges.Changes) != len(changes) {
-			t.Fatalf("got %d changes, expected %d", len(viewChanges.Changes), len(changes))
+		var all [][2]any
+		for len(all) < len(changes) {
+			var viewChanges EventViewChanges
+			evr.Get("viewChanges", &viewChanges)
+			all = append(all, viewChanges.Changes...)
+		}
+		if len(all) != len(changes) {
+			t.Fatalf("got %d changes, expected %d", len(all), len(changes))
 		}
 		for i, dst := range changes {
-			src := viewChanges.Changes[i]
+			src := all[i]
 			dstType := reflect.TypeOf(dst).Elem().Name()
 			if src[0] != dstType {
 				t.Fatalf("change %d is of type %s, expected %s", i, src[0], dstType)
@@ -412,7 +421,7 @@ func TestView(t *testing.T) {
 	tdeliver(t, acc, inboxNew)
 	var chmsgadd ChangeMsgAdd
 	var chmbcounts ChangeMailboxCounts
-	getChanges(&chmsgadd, &chmbcounts)
+	getChanges(&chmbcounts, &chmsgadd)
 	tcompare(t, chmsgadd.ChangeAddUID.MailboxID, inbox.ID)
 	tcompare(t, chmsgadd.MessageItems[0].Message.ID, inboxNew.ID)
 	chmbcounts.Size = 0
@@ -428,7 +437,7 @@ func TestView(t *testing.T) {
 	api.FlagsAdd(ctx, []int64{inboxNew.ID}, []string{`\seen`, `changelabel`, `aaa`})
 	var chmsgflags ChangeMsgFlags
 	var chmbkeywords ChangeMailboxKeywords
-	getChanges(&chmsgflags, &chmbcounts, &chmbkeywords)
+	getChanges(&chmbcounts, &chmbkeywords, &chmsgflags)
 	tcompare(t, chmsgadd.ChangeAddUID.MailboxID, inbox.ID)
 	tcompare(t, chmbkeywords, ChangeMailboxKeywords{
 		ChangeMailboxKeywords: store.ChangeMailboxKeywords{
@@ -491,13 +500,154 @@ func TestView(t *testing.T) {
 	tcompare(t, chmsgthread.ChangeThread, store.ChangeThread{MessageIDs: []int64{trashAlt.ID}, Muted: false, Collapsed: true})
 	tcompare(t, chmsgthread2.ChangeThread, store.ChangeThread{MessageIDs: []int64{inboxAltReply.ID}, Muted: false, Collapsed: true})
 
+	// Threads, with the thread of trashAlt and its reply in the inbox across mailboxes.
+	threads := api.Threads(ctx, inbox.ID, 0, 10)
+	i := slices.IndexFunc(threads, func(ts ThreadSummary) bool { return ts.ThreadID == trashAlt.m.ThreadID })
+	if i < 0 {
+		t.Fatalf("missing thread for message in inbox")
+	}
+	ts := threads[i]
+	tcompare(t, ts.MessageIDs, []int64{trashAlt.ID, inboxAltReply.ID})
+	tcompare(t, ts.MailboxIDs, []int64{trashAlt.m.MailboxID, inbox.ID})
+	tcompare(t, ts.Unread, 2)
+	tcompare(t, len(ts.Participants), 1)
+	tcompare(t, ts.Participants[0].User, "mjl")
+	tcompare(t, ts.Subject, "Re: test")
+	tcompare(t, ts.Snippet, "reply to alt\n")
+	tcompare(t, ts.Muted, false)
+	tcompare(t, ts.Collapsed, true)
+	tcompare(t, len(api.Threads(ctx, inbox.ID, len(threads), 10)), 0)
+	tcompare(t, len(api.Threads(ctx, 0, 0, 1)), 1)
+	tneedError(t, func() { api.Threads(ctx, 0, 0, 0) })
+
+	// Mute and expand entire threads.
+	api.ThreadsMute(ctx, []int64{ts.ThreadID}, true)
+	getChanges(&chmsgthread, &chmsgthread2)
+	tcompare(t, chmsgthread.ChangeThread, store.ChangeThread{MessageIDs: []int64{trashAlt.ID}, Muted: true, Collapsed: true})
+	tcompare(t, chmsgthread2.ChangeThread, store.ChangeThread{MessageIDs: []int64{inboxAltReply.ID}, Muted: true, Collapsed: true})
+	api.ThreadsCollapse(ctx, []int64{ts.ThreadID}, false)
+	getChanges(&chmsgthread, &chmsgthread2)
+	tcompare(t, chmsgthread.ChangeThread, store.ChangeThread{MessageIDs: []int64{trashAlt.ID}, Muted: true, Collapsed: false})
+	tcompare(t, chmsgthread2.ChangeThread, store.ChangeThread{MessageIDs: []int64{inboxAltReply.ID}, Muted: true, Collapsed: false})
+	threads = api.Threads(ctx, inbox.ID, 0, 10)
+	i = slices.IndexFunc(threads, func(ts ThreadSummary) bool { return ts.ThreadID == trashAlt.m.ThreadID })
+	tcompare(t, threads[i].Muted, true)
+	tcompare(t, threads[i].Collapsed, false)
+	tneedError(t, func() { api.ThreadsMute(ctx, nil, true) })
+
+	// Resume the view after reconnecting, getting only the changes since the last
+	// event instead of all messages.
+	resumeID := *evr.id
+	if resumeID == 0 {
+		t.Fatalf("missing event id")
+	}
+	api.FlagsAdd(ctx, []int64{inboxAltReply.ID}, []string{`\flagged`})
+	inboxResume := &testmsg{"Inbox", store.Flags{}, nil, msgMinimal, zerom, 0}
+	tdeliver(t, acc, inboxResume)
+	api.MessageDelete(ctx, []int64{inboxFlags.ID})
+
+	resumeConn := func(resume ViewResume) (EventStart, eventReader) {
+		t.Helper()
+		resumeJSON, err := json.Marshal(resume)
+		tcheck(t, err, "marshal resume json")
+		req, err := http.NewRequest("GET", eventsURL+"?singleUseToken="+api.Token(ctx)+"&request="+string(requestJSON)+"&resume="+url.QueryEscape(string(resumeJSON)), nil)
+		tcheck(t, err, "making request")
+		resp, err := http.DefaultClient.Do(req)
+		tcheck(t, err, "http transaction")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("got statuscode %d, expected %d", resp.StatusCode, http.StatusOK)
+		}
+		xevr := eventReader{t, bufio.NewReader(resp.Body), resp.Body, new(int64)}
+		var xstart EventStart
+		xevr.Get("start", &xstart)
+		return xstart, xevr
+	}
+
+	xstart, xevr := resumeConn(ViewResume{LastEventID: resumeID, ViewEnd: true})
+	tcompare(t, xstart.Resumed, true)
+	var resumeChanges EventViewChanges
+	xevr.Get("viewChanges", &resumeChanges)
+	tcompare(t, len(resumeChanges.Changes), 3)
+	tcompare(t, resumeChanges.Changes[0][0], "ChangeMsgFlags")
+	tcompare(t, resumeChanges.Changes[1][0], "ChangeMsgAdd")
+	tcompare(t, resumeChanges.Changes[2][0], "ChangeMsgRemove")
+	if *xevr.id <= resumeID {
+		t.Fatalf("event id %d not after resumed event id %d", *xevr.id, resumeID)
+	}
+	xevr.r.Close()
+
+	// Unknown event ID, from the future, results in a regular view.
+	xstart, xevr = resumeConn(ViewResume{LastEventID: *xevr.id + 100, ViewEnd: true})
+	tcompare(t, xstart.Resumed, false)
+	xevr.Get("viewMsgs", &viewMsgs)
+	tcompare(t, len(viewMsgs.MessageItems), 2)
+	xevr.r.Close()
+
+	// A connection that stops because of too many pending changes still marks the
+	// removals it got as seen, so the removed message is erased and no references to
+	// the account are kept.
+	origMax, origDelay := store.CommPendingChangesMax, changesDelay
+	store.CommPendingChangesMax = 3
+	changesDelay = time.Second
+	defer func() {
+		store.CommPendingChangesMax, changesDelay = origMax, origDelay
+	}()
+	_, xevr = resumeConn(ViewResume{})
+	xevr.Get("viewMsgs", &viewMsgs)
+	api.MessageDelete(ctx, []int64{inboxResume.ID})
+	api.FlagsAdd(ctx, []int64{inboxAltReply.ID}, []string{`\seen`})
+	var fatalErr string
+	xevr.Get("fatalErr", &fatalErr)
+	tcompare(t, fatalErr, "out of sync, too many pending changes")
+	xevr.r.Close()
+	p := acc.MessagePath(inboxResume.ID)
+	for i := 0; ; i++ {
+		if _, err := os.Stat(p); errors.Is(err, fs.ErrNotExist) {
+			break
+		} else if i == 100 {
+			t.Fatalf("removed message file not erased")
+		}
+		time.Sleep(time.Second / 20)
+	}
+
 	// todo: check move operations and their changes, e.g. MailboxDelete, MailboxEmpty, MessageRemove.
 }
 
+func TestCoalesceChanges(t *testing.T) {
+	flags := func(mailboxID int64, uid store.UID, mask, flags store.Flags) store.ChangeFlags {
+		return store.ChangeFlags{MailboxID: mailboxID, UID: uid, Mask: mask, Flags: flags}
+	}
+	counts := func(mailboxID int64, total int64) store.ChangeMailboxCounts {
+		return store.ChangeMailboxCounts{MailboxID: mailboxID, MailboxCounts: store.MailboxCounts{Total: total}}
+	}
+	add := store.ChangeAddUID{MailboxID: 1, UID: 3}
+
+	changes := []store.Change{
+		flags(1, 1, store.Flags{Seen: true}, store.Flags{Seen: true}),
+		counts(1, 10),
+		flags(1, 2, store.Flags{Seen: true}, store.Flags{Seen: true}),
+		add,
+		flags(1, 1, store.Flags{Flagged: true}, store.Flags{Seen: true, Flagged: true}),
+		counts(1, 11),
+		counts(2, 5),
+	}
+	tcompare(t, coalesceChanges(changes), []store.Change{
+		flags(1, 2, store.Flags{Seen: true}, store.Flags{Seen: true}),
+		add,
+		flags(1, 1, store.Flags{Seen: true, Flagged: true}, store.Flags{Seen: true, Flagged: true}),
+		counts(1, 11),
+		counts(2, 5),
+	})
+
+	// Nothing to combine.
+	tcompare(t, coalesceChanges([]store.Change{add}), []store.Change{add})
+}
+
 type eventReader struct {
 	t  *testing.T
 	br *bufio.Reader
 	r  io.Closer
+	id *int64 // From last "id" field.
 }
 
 func (r eventReader) Get(name string, event any) {
@@ -518,7 +668,11 @@ func (r eventReader) Get(name string, event any) {
 		line = bytes.TrimRight(line, "\n")
 		// fmt.Printf("have line %s\n", line)
 
-		if bytes.HasPrefix(line, []byte("event: ")) {
+		if bytes.HasPrefix(line, []byte("id: ")) {
+			id, err := strconv.ParseInt(string(line[len("id: "):]), 10, 64)
+			tcheck(t, err, "parsing event id")
+			*r.id = id
+		} else if bytes.HasPrefix(line, []byte("event: ")) {
 			ev = string(line[len("event: "):])
 		} else if bytes.HasPrefix(line, []byte("data: ")) {
 			data = line[len("data: "):]
@@ -34,11 +34,11 @@ import (
 	"github.com/mjl-/bstore"
 	"github.com/mjl-/sherpa"
 
+	"github.com/mjl-/mox/config"
 	"github.com/mjl-/mox/message"
 	"github.com/mjl-/mox/metrics"
 	"github.com/mjl-/mox/mlog"
 	"github.com/mjl-/mox/mox-"
-	"github.com/mjl-/mox/moxio"
 	"github.com/mjl-/mox/store"
 	"github.com/mjl-/mox/webauth"
 	"github.com/mjl-/mox/webops"
@@ -62,6 +62,39 @@ type requestInfo struct {
 	Request      *http.Request // For Proto and TLS connection state during message submit.
 }
 
+// API methods that don't make changes, allowed in read-only impersonation
+// sessions.
+var impersonateReadMethods = map[string]bool{
+	"LoginPrep":              true,
+	"Login":                  true,
+	"PasskeyLoginStart":      true,
+	"PasskeyLogin":           true,
+	"OIDCEnabled":            true,
+	"Logout":                 true,
+	"Version":                true,
+	"Token":                  true,
+	"Request":                true,
+	"ParsedMessage":          true,
+	"MessageFindMessageID":   true,
+	"MessageJunkVerdict":     true,
+	"MessageResponse":        true,
+	"UnsubscribeHistory":     true,
+	"CompleteRecipient":      true,
+	"Threads":                true,
+	"RecipientSecurity":      true,
+	"DecodeMIMEWords":        true,
+	"Templates":              true,
+	"TemplateApply":          true,
+	"RulesetSuggestMove":     true,
+	"Rules":                  true,
+	"SavedSearches":          true,
+	"Drafts":                 true,
+	"Delegations":            true,
+	"DelegatedMessages":      true,
+	"DelegatedParsedMessage": true,
+	"SSETypes":               true,
+}
+
 //go:embed webmail.html
 var webmailHTML []byte
 
@@ -80,12 +113,15 @@ var webmailtextHTML []byte
 //go:embed text.js
 var webmailtextJS []byte
 
+//go:embed sw.js
+var webmailswJS []byte
+
 var (
 	// Similar between ../webmail/webmail.go:/metricSubmission and ../smtpserver/server.go:/metricSubmission and ../webapisrv/server.go:/metricSubmission
 	metricSubmission = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "mox_webmail_submission_total",
-			Help: "Webmail message submission results, known values (those ending with error are server errors): ok, badfrom, messagelimiterror, recipientlimiterror, queueerror, storesenterror, domaindisabled.",
+			Help: "Webmail message submission results, known values (those ending with error are server errors): ok, badfrom, messagelimiterror, recipientlimiterror, queueerror, storesenterror, domaindisabled, suspended, throttled.",
 		},
 		[]string{
 			"result",
@@ -100,6 +136,15 @@ var (
 			"error",
 		},
 	)
+	metricProxy = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mox_webmail_proxy_requests_total",
+			Help: "Requests for remote resources through the webmail proxy, by result: fetched, cached, error.",
+		},
+		[]string{
+			"result",
+		},
+	)
 	metricSSEConnections = promauto.NewGauge(
 		prometheus.GaugeOpts{
 			Name: "mox_webmail_sse_connections",
@@ -225,11 +270,11 @@ func Handler(maxMessageSize int64, cookiePath string, isForwarded bool, accountP
 			http.Error(w, "500 - internal server error - cannot handle requests", http.StatusInternalServerError)
 			return
 		}
-		handle(sh, isForwarded, accountPath, w, r)
+		handle(sh, cookiePath, isForwarded, accountPath, w, r)
 	}
 }
 
-func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w http.ResponseWriter, r *http.Request) {
+func handle(apiHandler http.Handler, cookiePath string, isForwarded bool, accountPath string, w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	log := pkglog.WithContext(ctx).With(slog.String("userauth", ""))
 
@@ -262,6 +307,11 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		}
 	}()
 
+	// Per-domain customization of the interface, without authentication.
+	if webauth.ServeBranding(log, isForwarded, w, r) {
+		return
+	}
+
 	switch r.URL.Path {
 	case "/":
 		switch r.Method {
@@ -286,7 +336,7 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		}
 		return
 
-	case "/msg.js", "/text.js":
+	case "/msg.js", "/text.js", "/sw.js":
 		switch r.Method {
 		default:
 			http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
@@ -296,13 +346,43 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 
 		path := filepath.Join("webmail", r.URL.Path[1:])
 		var fallback = webmailmsgJS
-		if r.URL.Path == "/text.js" {
+		switch r.URL.Path {
+		case "/text.js":
 			fallback = webmailtextJS
+		case "/sw.js":
+			// Service worker, for showing Web Push notifications about new messages.
+			fallback = webmailswJS
 		}
 
 		w.Header().Set("Content-Type", "application/javascript; charset=utf-8")
 		serveContentFallback(log, w, r, path, fallback, false)
 		return
+
+	case "/proxy":
+		// Remote resources referenced from HTML messages. Not authenticated with a session
+		// cookie, the browser doesn't send it from the sandboxed message iframe. The URL
+		// is signed instead.
+		switch r.Method {
+		case "GET", "HEAD":
+			serveProxy(ctx, log, w, r)
+		default:
+			http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
+		}
+		return
+
+	case "/oidc/login":
+		// Login through the OpenID Connect identity provider, if configured.
+		webauth.OIDCLogin(ctx, log, webauth.Accounts, "webmail", cookiePath, isForwarded, w, r)
+		return
+
+	case "/oidc/callback":
+		webauth.OIDCCallback(ctx, log, webauth.Accounts, "webmail", cookiePath, isForwarded, w, r)
+		return
+
+	case "/impersonate":
+		// Session for an admin acting as the account, started from the admin interface.
+		webauth.Impersonate(ctx, log, "webmail", cookiePath, isForwarded, w, r)
+		return
 	}
 
 	isAPI := strings.HasPrefix(r.URL.Path, "/api/")
@@ -314,8 +394,8 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 
 	var loginAddress, accName string
 	var sessionToken store.SessionToken
-	// All other URLs, except the login endpoint require some authentication.
-	if r.URL.Path != "/api/LoginPrep" && r.URL.Path != "/api/Login" {
+	// All other URLs, except the login endpoints require some authentication.
+	if !webauth.IsLoginPath(r.URL.Path) {
 		var ok bool
 		isExport := r.URL.Path == "/export"
 		requireCSRF := isAPI || isExport
@@ -326,6 +406,22 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		}
 	}
 
+	// Sessions of admins acting as the account can be read-only, and their API calls
+	// are recorded in the audit log.
+	var session store.LoginSession
+	if sessionToken != "" {
+		var err error
+		session, err = store.SessionGet(ctx, log, accName, sessionToken)
+		if err != nil {
+			http.Error(w, "403 - forbidden - "+err.Error(), http.StatusForbidden)
+			return
+		}
+	}
+	if session.ImpersonatedBy != "" && r.URL.Path == "/export" {
+		http.Error(w, "403 - forbidden - export not allowed while impersonating", http.StatusForbidden)
+		return
+	}
+
 	if isAPI {
 		var acc *store.Account
 		if accName != "" {
@@ -344,18 +440,63 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		}
 		reqInfo := requestInfo{log, loginAddress, acc, sessionToken, w, r}
 		ctx = context.WithValue(ctx, requestInfoCtxKey, reqInfo)
-		apiHandler.ServeHTTP(w, r.WithContext(ctx))
+		method := strings.TrimPrefix(r.URL.Path, "/api/")
+		if session.ImpersonatedBy == "" || method == "" {
+			apiHandler.ServeHTTP(w, r.WithContext(ctx))
+			return
+		}
+
+		event := store.AuditEvent{Kind: store.AuditImpersonate, Account: accName, Actor: session.ImpersonatedBy, Protocol: "webmail", Action: method}
+		if session.ReadOnly && !impersonateReadMethods[method] {
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			result := struct {
+				Error sherpa.Error `json:"error"`
+			}{
+				sherpa.Error{Code: "user:forbidden", Message: "not allowed in read-only impersonation session"},
+			}
+			err := json.NewEncoder(w).Encode(result)
+			log.Check(err, "writing error response")
+			event.Result = result.Error.Message
+			if ip := webauth.RemoteIP(log, isForwarded, r); ip != nil {
+				event.RemoteIP = ip.String()
+			}
+			store.AuditAdd(ctx, log, event)
+			return
+		}
+		webauth.AuditAPICall(ctx, log, isForwarded, apiHandler, w, r.WithContext(ctx), event)
 		return
 	}
 
 	// We are now expecting the following URLs:
+	// .../bimilogo
 	// .../export
 	// .../msg/<msgid>/{attachments.zip,parsedmessage.js,raw}
 	// .../msg/<msgid>/{,msg}{text,html,htmlexternal}
 	// .../msg/<msgid>/{view,viewtext,download}/<partid>
 
+	if r.URL.Path == "/bimilogo" {
+		// Logos of senders, for messages that passed BIMI evaluation during delivery.
+		// Loaded by the webmail itself, so with session cookie. Signed like proxy URLs,
+		// so only logos of messages can be fetched.
+		switch r.Method {
+		case "GET", "HEAD":
+			serveBIMILogo(ctx, log, w, r)
+		default:
+			http.Error(w, "405 - method not allowed - use get", http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
 	if r.URL.Path == "/export" {
-		webops.Export(log, accName, w, r)
+		if mox.Conf.WebFeatureDisabled(accName, config.WebFeatureExport) {
+			http.Error(w, "403 - forbidden - export is disabled for this account", http.StatusForbidden)
+			return
+		}
+		var remoteIP string
+		if ip := webauth.RemoteIP(log, isForwarded, r); ip != nil {
+			remoteIP = ip.String()
+		}
+		webops.Export(log, accName, "webmail", remoteIP, w, r)
 		return
 	}
 
@@ -380,8 +521,8 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 	// fetch/prepare and cleanup. We only do all the work when the request seems legit
 	// (valid HTTP route and method).
 	xprepare := func() (acc *store.Account, moreHeaders []string, m store.Message, msgr *store.MsgReader, p message.Part, cleanup func(), ok bool) {
-		if r.Method != "GET" {
-			http.Error(w, "405 - method not allowed - post required", http.StatusMethodNotAllowed)
+		if r.Method != "GET" && r.Method != "HEAD" {
+			http.Error(w, "405 - method not allowed - get required", http.StatusMethodNotAllowed)
 			return
 		}
 
@@ -655,9 +796,10 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 			h.Set("Content-Disposition", cd)
 		}
 		h.Set("Cache-Control", "no-store, max-age=0")
+		// Message contents don't change, the ID is a strong validator for range requests.
+		h.Set("ETag", fmt.Sprintf(`"%d"`, m.ID))
 
-		_, err := io.Copy(w, &moxio.AtReader{R: msgr})
-		log.Check(err, "writing raw")
+		http.ServeContent(w, r, "", time.Time{}, io.NewSectionReader(msgr, 0, msgr.Size()))
 
 	case len(t) == 2 && (t[1] == "msgtext" || t[1] == "msghtml" || t[1] == "msghtmlexternal"):
 		// msg.html has a javascript tag with message data, and javascript to render the
@@ -709,10 +851,11 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		pmjson, err := json.Marshal(pm)
 		xcheckf(ctx, err, "marshal parsedmessage")
 
+		auth := parseAuthResults(log, m.MsgPrefix, m.Auth)
 		m.MsgPrefix = nil
 		m.ParsedBuf = nil
 		hl := messageItemMoreHeaders(moreHeaders, pm)
-		mi := MessageItem{m, pm.envelope, pm.attachments, pm.isSigned, pm.isEncrypted, false, hl}
+		mi := MessageItem{m, pm.envelope, pm.attachments, pm.isSigned, pm.isEncrypted, pm.smime, pm.calendar, pm.unsubscribe, auth, false, hl, nil}
 		mijson, err := json.Marshal(mi)
 		xcheckf(ctx, err, "marshal messageitem")
 
@@ -762,20 +905,31 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 	case len(t) == 2 && (t[1] == "html" || t[1] == "htmlexternal"):
 		// Returns the first HTML part, with "cid:" URIs replaced with an inlined datauri
 		// if the referenced Content-ID attachment can be found.
-		_, _, _, _, p, cleanup, ok := xprepare()
+		acc, _, _, _, p, cleanup, ok := xprepare()
 		if !ok {
 			return
 		}
 		defer cleanup()
 
+		settings := store.Settings{ID: 1}
+		err := acc.DB.Get(ctx, &settings)
+		xcheckf(ctx, err, "get settings")
+		policy := displayHTMLPolicy(settings)
+		allowExternal := strings.HasSuffix(t[1], "external")
+		// With the proxy, external images are loaded from our own origin.
+		proxy := allowExternal && settings.RemoteContentProxy
+		var ps *proxySession
+		if proxy {
+			ps = &proxySession{accName, session.ID}
+		}
+
 		setHeaders := func() {
 			// Needed for inner document height for outer iframe height in separate message
 			// view. We only need that when displaying as a separate message on the msghtml*
 			// endpoints. When displaying in the regular webmail, we don't need to know the
 			// inner height so we load it as different origin, which should be safer.
 			sameorigin := r.URL.Query().Get("sameorigin") == "true"
-			allowExternal := strings.HasSuffix(t[1], "external")
-			headers(sameorigin, allowExternal, false, false)
+			headers(sameorigin, allowExternal && !proxy, false, proxy)
 
 			h.Set("Content-Type", "text/html; charset=utf-8")
 			h.Set("Cache-Control", "no-store, max-age=0")
@@ -792,7 +946,7 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 			switch mt {
 			case "TEXT/HTML":
 				done = true
-				err := inlineSanitizeHTML(log, setHeaders, w, p, parents)
+				err := inlineSanitizeHTML(log, setHeaders, w, p, parents, policy, ps)
 				if err != nil {
 					http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
 				}
@@ -809,6 +963,63 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 			http.Error(w, "400 - bad request - no html part in message", http.StatusBadRequest)
 		}
 
+	case len(t) == 2 && (t[1] == "print" || t[1] == "print.pdf"):
+		// Self-contained HTML document for printing or archiving a message, with the
+		// text, or with the HTML part if query parameter "html" is "true". Or a PDF with
+		// the text of the message.
+		acc, _, m, msgr, p, cleanup, ok := xprepare()
+		if !ok {
+			return
+		}
+		defer cleanup()
+
+		state := msgState{acc: acc, m: m, msgr: msgr, part: &p}
+		// note: state is cleared by cleanup
+
+		pm, err := parsedMessage(log, &m, &state, true, false, false)
+		xcheckf(ctx, err, "parsing message")
+
+		if t[1] == "print.pdf" {
+			headers(false, false, false, false)
+			h.Set("Content-Type", "application/pdf")
+			filename := fmt.Sprintf("email-%d-%s.pdf", m.ID, m.Received.Format("20060102-150405"))
+			h.Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": filename}))
+			h.Set("Cache-Control", "no-store, max-age=0")
+			err := printPDF(w, pm.envelope, pm.Texts)
+			log.Check(err, "writing pdf")
+			return
+		}
+
+		var htmlPart *message.Part
+		var parents []*message.Part
+		if r.URL.Query().Get("html") == "true" {
+			if !pm.HasHTML {
+				http.Error(w, "400 - bad request - no html part in message", http.StatusBadRequest)
+				return
+			}
+			htmlPart = &p
+			for _, index := range pm.HTMLPath {
+				if index < 0 || index >= len(htmlPart.Parts) {
+					http.Error(w, "400 - bad request - invalid html part path", http.StatusBadRequest)
+					return
+				}
+				parents = append(parents, htmlPart)
+				htmlPart = &htmlPart.Parts[index]
+			}
+		}
+
+		settings := store.Settings{ID: 1}
+		err = acc.DB.Get(ctx, &settings)
+		xcheckf(ctx, err, "get settings")
+
+		headers(false, false, false, false)
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		h.Set("Cache-Control", "no-store, max-age=0")
+		err = printHTML(log, w, pm.envelope, pm.Texts, htmlPart, parents, displayHTMLPolicy(settings))
+		if err != nil {
+			http.Error(w, "400 - bad request - "+err.Error(), http.StatusBadRequest)
+		}
+
 	case len(t) == 3 && (t[1] == "view" || t[1] == "viewtext" || t[1] == "download"):
 		// View any part, as referenced in the last element path. "0" is the whole message,
 		// 0.0 is the first subpart, etc. "view" returns it with the content-type from the
@@ -816,7 +1027,7 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		// data with a text/plain content-type so the browser will attempt to display it,
 		// and "download" adds a content-disposition header causing the browser the
 		// download the file.
-		_, _, _, _, p, cleanup, ok := xprepare()
+		_, _, m, _, p, cleanup, ok := xprepare()
 		if !ok {
 			return
 		}
@@ -846,35 +1057,142 @@ func handle(apiHandler http.Handler, isForwarded bool, accountPath string, w htt
 		}
 		h.Set("Content-Type", ct)
 		h.Set("Cache-Control", "no-store, max-age=0")
+		h.Set("ETag", fmt.Sprintf(`"%d-%s"`, m.ID, t[2]))
+		_, name, err := ap.DispositionFilename()
+		if err != nil && errors.Is(err, message.ErrParamEncoding) {
+			log.Debugx("parsing disposition/filename", err)
+		} else {
+			xcheckf(ctx, err, "reading disposition/filename")
+		}
 		if t[1] == "download" {
-			_, name, err := ap.DispositionFilename()
-			if err != nil && errors.Is(err, message.ErrParamEncoding) {
-				log.Debugx("parsing disposition/filename", err)
-			} else {
-				xcheckf(ctx, err, "reading disposition/filename")
-			}
 			if name == "" {
 				name = "attachment.bin"
 			}
 			cd := mime.FormatMediaType("attachment", map[string]string{"filename": name})
 			h.Set("Content-Disposition", cd)
+		} else if name != "" && t[1] == "view" {
+			// For the filename when the user saves the viewed part.
+			cd := mime.FormatMediaType("inline", map[string]string{"filename": name})
+			h.Set("Content-Disposition", cd)
 		}
 
-		_, err := io.Copy(w, ap.Reader())
-		log.Check(err, "copying attachment")
+		// Range requests allow for resumed downloads, and playing media from an offset.
+		http.ServeContent(w, r, "", time.Time{}, &partReadSeeker{p: &ap})
+
+	case len(t) == 3 && t[1] == "thumbnail":
+		// Scaled down JPEG of an image part, or preview of a PDF part. The size is
+		// selected with query parameter "size": small, medium (default) or large.
+		sizeName := r.URL.Query().Get("size")
+		if sizeName == "" {
+			sizeName = "medium"
+		}
+		size, ok := thumbnailSizes[sizeName]
+		if !ok {
+			http.Error(w, "400 - bad request - unknown size", http.StatusBadRequest)
+			return
+		}
+
+		acc, _, m, _, p, cleanup, ok := xprepare()
+		if !ok {
+			return
+		}
+		defer cleanup()
+
+		paths := strings.Split(t[2], ".")
+		if len(paths) == 0 || paths[0] != "0" {
+			http.NotFound(w, r)
+			return
+		}
+		ap := p
+		for _, e := range paths[1:] {
+			index, err := strconv.ParseInt(e, 10, 32)
+			if err != nil || index < 0 || int(index) >= len(ap.Parts) {
+				http.NotFound(w, r)
+				return
+			}
+			ap = ap.Parts[int(index)]
+		}
+
+		key := fmt.Sprintf("%s/%d/%s/%s", acc.Name, m.ID, t[2], sizeName)
+		data, ok := thumbnailCacheGet(key)
+		if !ok {
+			var err error
+			data, err = thumbnail(&ap, size)
+			if err != nil {
+				log.Debugx("generating thumbnail", err, slog.Int64("msgid", m.ID), slog.String("path", t[2]))
+				http.NotFound(w, r)
+				return
+			}
+			thumbnailCacheAdd(key, data)
+		}
+
+		headers(false, false, false, false)
+		h.Set("Content-Type", "image/jpeg")
+		// Message contents don't change, so thumbnails can be cached by the browser.
+		h.Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(thumbnailCacheMaxAge.Seconds())))
+		h.Set("ETag", fmt.Sprintf(`"%d-%s-%s"`, m.ID, t[2], sizeName))
+		http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
 	default:
 		http.NotFound(w, r)
 	}
 }
 
+// partReadSeeker is an io.ReadSeeker for the decoded content of a part, for
+// serving HTTP range requests. Decoded content can't be read from an arbitrary
+// offset, so seeking backwards starts reading from the start of the part again,
+// skipping data up to the offset.
+type partReadSeeker struct {
+	p      *message.Part
+	r      io.Reader
+	pos    int64 // Offset of r in decoded content.
+	offset int64 // Offset for next read.
+}
+
+func (prs *partReadSeeker) Read(buf []byte) (int, error) {
+	if prs.r == nil || prs.pos > prs.offset {
+		prs.r = prs.p.Reader()
+		prs.pos = 0
+	}
+	if prs.pos < prs.offset {
+		n, err := io.CopyN(io.Discard, prs.r, prs.offset-prs.pos)
+		prs.pos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+	n, err := prs.r.Read(buf)
+	prs.pos += int64(n)
+	prs.offset = prs.pos
+	return n, err
+}
+
+func (prs *partReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += prs.offset
+	case io.SeekEnd:
+		offset += prs.p.DecodedSize
+	default:
+		return 0, fmt.Errorf("bad whence %d", whence)
+	}
+	if offset < 0 {
+		return 0, fmt.Errorf("negative offset")
+	}
+	prs.offset = offset
+	return offset, nil
+}
+
 // inlineSanitizeHTML writes the part as HTML, with "cid:" URIs for html "src"
-// attributes inlined and with potentially dangerous tags removed (javascript). The
+// attributes inlined and with potentially dangerous tags removed (javascript). If
+// policy is not nil, elements and attributes it does not allow are removed too. If
+// proxy is not nil, URLs of images are rewritten to load through the proxy. The
 // sanitizing is just a first layer of defense, CSP headers block execution of
 // scripts. If the HTML becomes too large, an error is returned. Before writing
 // HTML, setHeaders is called to write the required headers for content-type and
 // CSP. On error, setHeader is not called, no output is written and the caller
 // should write an error response.
-func inlineSanitizeHTML(log mlog.Log, setHeaders func(), w io.Writer, p *message.Part, parents []*message.Part) error {
+func inlineSanitizeHTML(log mlog.Log, setHeaders func(), w io.Writer, p *message.Part, parents []*message.Part, policy *htmlPolicy, proxy *proxySession) error {
 	node, err := html.Parse(p.ReaderUTF8OrBinary())
 	if err != nil {
 		return fmt.Errorf("parsing html: %v", err)
@@ -885,6 +1203,12 @@ func inlineSanitizeHTML(log mlog.Log, setHeaders func(), w io.Writer, p *message
 	if err := inlineNode(p, parents, node, &totalSize); err != nil {
 		return fmt.Errorf("inline cid uris in html nodes: %w", err)
 	}
+	if policy != nil {
+		policy.sanitize(node)
+	}
+	if proxy != nil {
+		proxy.proxyNode(node, time.Now())
+	}
 	sanitizeNode(node)
 	setHeaders()
 	err = html.Render(w, node)
@@ -271,6 +271,15 @@ var api;
 		ViewMode["ModeHTML"] = "html";
 		ViewMode["ModeHTMLExt"] = "htmlext";
 	})(ViewMode = api.ViewMode || (api.ViewMode = {}));
+	// BulkOp is an operation applied to all messages matching a query.
+	let BulkOp;
+	(function (BulkOp) {
+		BulkOp["BulkMarkRead"] = "markread";
+		BulkOp["BulkMarkUnread"] = "markunread";
+		BulkOp["BulkMove"] = "move";
+		BulkOp["BulkLabel"] = "label";
+		BulkOp["BulkDelete"] = "delete";
+	})(BulkOp = api.BulkOp || (api.BulkOp = {}));
 	// SecurityResult indicates whether a security feature is supported.
 	let SecurityResult;
 	(function (SecurityResult) {
@@ -289,39 +298,90 @@ var api;
 		Quoting["Bottom"] = "bottom";
 		Quoting["Top"] = "top";
 	})(Quoting = api.Quoting || (api.Quoting = {}));
-	api.structTypes = { "Address": true, "Attachment": true, "ChangeMailboxAdd": true, "ChangeMailboxCounts": true, "ChangeMailboxKeywords": true, "ChangeMailboxRemove": true, "ChangeMailboxRename": true, "ChangeMailboxSpecialUse": true, "ChangeMsgAdd": true, "ChangeMsgFlags": true, "ChangeMsgRemove": true, "ChangeMsgThread": true, "ComposeMessage": true, "Domain": true, "DomainAddressConfig": true, "Envelope": true, "EventStart": true, "EventViewChanges": true, "EventViewErr": true, "EventViewMsgs": true, "EventViewReset": true, "File": true, "Filter": true, "Flags": true, "ForwardAttachments": true, "FromAddressSettings": true, "Mailbox": true, "Message": true, "MessageAddress": true, "MessageEnvelope": true, "MessageItem": true, "NotFilter": true, "Page": true, "ParsedMessage": true, "Part": true, "Query": true, "RecipientSecurity": true, "Request": true, "Ruleset": true, "Settings": true, "SpecialUse": true, "SubmitMessage": true };
-	api.stringsTypes = { "AttachmentType": true, "CSRFToken": true, "Localpart": true, "Quoting": true, "SecurityResult": true, "ThreadMode": true, "ViewMode": true };
+	// ResponseMode is how to respond to a message.
+	let ResponseMode;
+	(function (ResponseMode) {
+		ResponseMode["ResponseReply"] = "reply";
+		ResponseMode["ResponseReplyAll"] = "replyall";
+		ResponseMode["ResponseReplyList"] = "replylist";
+		ResponseMode["ResponseForward"] = "forward";
+	})(ResponseMode = api.ResponseMode || (api.ResponseMode = {}));
+	// SenderWarningKind is the heuristic that triggered a sender warning.
+	let SenderWarningKind;
+	(function (SenderWarningKind) {
+		SenderWarningKind["SenderWarningDisplayName"] = "displayname";
+		SenderWarningKind["SenderWarningLookalike"] = "lookalike";
+		SenderWarningKind["SenderWarningReplyTo"] = "replyto";
+	})(SenderWarningKind = api.SenderWarningKind || (api.SenderWarningKind = {}));
+	api.structTypes = { "Address": true, "Attachment": true, "AuthCheck": true, "AuthResults": true, "BulkAction": true, "CalendarAttendee": true, "CalendarInvite": true, "ChangeMailboxAdd": true, "ChangeMailboxCounts": true, "ChangeMailboxKeywords": true, "ChangeMailboxRemove": true, "ChangeMailboxRename": true, "ChangeMailboxSpecialUse": true, "ChangeMsgAdd": true, "ChangeMsgFlags": true, "ChangeMsgRemove": true, "ChangeMsgThread": true, "ComposeMessage": true, "ComposeTemplate": true, "DelegatedAccess": true, "Delegation": true, "Domain": true, "DomainAddressConfig": true, "Draft": true, "DraftAttachment": true, "Envelope": true, "EventBulkProgress": true, "EventSavedSearches": true, "EventStart": true, "EventViewChanges": true, "EventViewErr": true, "EventViewMsgs": true, "EventViewReset": true, "File": true, "Filter": true, "Flags": true, "ForwardAttachments": true, "FromAddressSettings": true, "Identity": true, "Impersonation": true, "InlineFile": true, "Label": true, "ListUnsubscribe": true, "Mailbox": true, "Message": true, "MessageAddress": true, "MessageAuth": true, "MessageAuthDKIM": true, "MessageAuthDNSBL": true, "MessageEnvelope": true, "MessageItem": true, "MessageJunkVerdict": true, "MessageJunkWord": true, "NotFilter": true, "PGPResult": true, "Page": true, "ParsedMessage": true, "Part": true, "PasskeyAssertion": true, "PasskeyGetOptions": true, "Query": true, "RecipientSecurity": true, "Request": true, "ResponseCompose": true, "Rule": true, "Ruleset": true, "SMIMECertificate": true, "SMIMEStatus": true, "SavedSearch": true, "SenderWarning": true, "Settings": true, "SharedTemplate": true, "SpecialUse": true, "SubmitMessage": true, "SubmitResult": true, "Template": true, "TemplateAttachment": true, "TemplateRef": true, "ThreadSummary": true, "Unsubscribe": true, "ViewResume": true };
+	api.stringsTypes = { "AttachmentType": true, "BulkOp": true, "CSRFToken": true, "Localpart": true, "Quoting": true, "ResponseMode": true, "SecurityResult": true, "SenderWarningKind": true, "ThreadMode": true, "ViewMode": true };
 	api.intsTypes = { "ModSeq": true, "UID": true, "Validation": true };
 	api.types = {
-		"Request": { "Name": "Request", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Cancel", "Docs": "", "Typewords": ["bool"] }, { "Name": "Query", "Docs": "", "Typewords": ["Query"] }, { "Name": "Page", "Docs": "", "Typewords": ["Page"] }] },
+		"PasskeyGetOptions": { "Name": "PasskeyGetOptions", "Docs": "", "Fields": [{ "Name": "Challenge", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "RPID", "Docs": "", "Typewords": ["string"] }, { "Name": "Timeout", "Docs": "", "Typewords": ["int32"] }] },
+		"PasskeyAssertion": { "Name": "PasskeyAssertion", "Docs": "", "Fields": [{ "Name": "CredentialID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ClientDataJSON", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "AuthenticatorData", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"Request": { "Name": "Request", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Cancel", "Docs": "", "Typewords": ["bool"] }, { "Name": "KeepViews", "Docs": "", "Typewords": ["bool"] }, { "Name": "Query", "Docs": "", "Typewords": ["Query"] }, { "Name": "Page", "Docs": "", "Typewords": ["Page"] }] },
 		"Query": { "Name": "Query", "Docs": "", "Fields": [{ "Name": "OrderAsc", "Docs": "", "Typewords": ["bool"] }, { "Name": "Threading", "Docs": "", "Typewords": ["ThreadMode"] }, { "Name": "Filter", "Docs": "", "Typewords": ["Filter"] }, { "Name": "NotFilter", "Docs": "", "Typewords": ["NotFilter"] }] },
-		"Filter": { "Name": "Filter", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxChildrenIncluded", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Oldest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Newest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Headers", "Docs": "", "Typewords": ["[]", "[]", "string"] }, { "Name": "SizeMin", "Docs": "", "Typewords": ["int64"] }, { "Name": "SizeMax", "Docs": "", "Typewords": ["int64"] }] },
-		"NotFilter": { "Name": "NotFilter", "Docs": "", "Fields": [{ "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Filter": { "Name": "Filter", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxChildrenIncluded", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "WordHashes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Oldest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Newest", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Headers", "Docs": "", "Typewords": ["[]", "[]", "string"] }, { "Name": "SizeMin", "Docs": "", "Typewords": ["int64"] }, { "Name": "SizeMax", "Docs": "", "Typewords": ["int64"] }] },
+		"NotFilter": { "Name": "NotFilter", "Docs": "", "Fields": [{ "Name": "Words", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "WordHashes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["AttachmentType"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"Page": { "Name": "Page", "Docs": "", "Fields": [{ "Name": "AnchorMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Count", "Docs": "", "Typewords": ["int32"] }, { "Name": "DestMessageID", "Docs": "", "Typewords": ["int64"] }] },
-		"ParsedMessage": { "Name": "ParsedMessage", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }, { "Name": "Headers", "Docs": "", "Typewords": ["{}", "[]", "string"] }, { "Name": "ViewMode", "Docs": "", "Typewords": ["ViewMode"] }, { "Name": "Texts", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HasHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListReplyAddress", "Docs": "", "Typewords": ["nullable", "MessageAddress"] }, { "Name": "TextPaths", "Docs": "", "Typewords": ["[]", "[]", "int32"] }, { "Name": "HTMLPath", "Docs": "", "Typewords": ["[]", "int32"] }] },
+		"ParsedMessage": { "Name": "ParsedMessage", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }, { "Name": "Headers", "Docs": "", "Typewords": ["{}", "[]", "string"] }, { "Name": "ViewMode", "Docs": "", "Typewords": ["ViewMode"] }, { "Name": "Texts", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HasHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListReplyAddress", "Docs": "", "Typewords": ["nullable", "MessageAddress"] }, { "Name": "TextPaths", "Docs": "", "Typewords": ["[]", "[]", "int32"] }, { "Name": "HTMLPath", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "SMIMECertificate", "Docs": "", "Typewords": ["nullable", "SMIMECertificate"] }, { "Name": "SMIMEPinned", "Docs": "", "Typewords": ["string"] }] },
 		"Part": { "Name": "Part", "Docs": "", "Fields": [{ "Name": "BoundaryOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "HeaderOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "BodyOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "EndOffset", "Docs": "", "Typewords": ["int64"] }, { "Name": "RawLineCount", "Docs": "", "Typewords": ["int64"] }, { "Name": "DecodedSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "MediaType", "Docs": "", "Typewords": ["string"] }, { "Name": "MediaSubType", "Docs": "", "Typewords": ["string"] }, { "Name": "ContentTypeParams", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "ContentID", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentDescription", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentTransferEncoding", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentDisposition", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentMD5", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentLanguage", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ContentLocation", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Envelope", "Docs": "", "Typewords": ["nullable", "Envelope"] }, { "Name": "Parts", "Docs": "", "Typewords": ["[]", "Part"] }, { "Name": "Message", "Docs": "", "Typewords": ["nullable", "Part"] }] },
 		"Envelope": { "Name": "Envelope", "Docs": "", "Fields": [{ "Name": "Date", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "Sender", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "Address"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }] },
 		"Address": { "Name": "Address", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "User", "Docs": "", "Typewords": ["string"] }, { "Name": "Host", "Docs": "", "Typewords": ["string"] }] },
 		"MessageAddress": { "Name": "MessageAddress", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "User", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["Domain"] }] },
 		"Domain": { "Name": "Domain", "Docs": "", "Fields": [{ "Name": "ASCII", "Docs": "", "Typewords": ["string"] }, { "Name": "Unicode", "Docs": "", "Typewords": ["string"] }] },
+		"SMIMECertificate": { "Name": "SMIMECertificate", "Docs": "", "Fields": [{ "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Issuer", "Docs": "", "Typewords": ["string"] }, { "Name": "SerialNumber", "Docs": "", "Typewords": ["string"] }, { "Name": "NotBefore", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "NotAfter", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Chain", "Docs": "", "Typewords": ["[]", "string"] }] },
 		"FromAddressSettings": { "Name": "FromAddressSettings", "Docs": "", "Fields": [{ "Name": "FromAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "ViewMode", "Docs": "", "Typewords": ["ViewMode"] }] },
-		"ComposeMessage": { "Name": "ComposeMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }] },
-		"SubmitMessage": { "Name": "SubmitMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "File"] }, { "Name": "ForwardAttachments", "Docs": "", "Typewords": ["ForwardAttachments"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureRelease", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "ArchiveThread", "Docs": "", "Typewords": ["bool"] }, { "Name": "ArchiveReferenceMailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }] },
-		"File": { "Name": "File", "Docs": "", "Fields": [{ "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"MessageJunkVerdict": { "Name": "MessageJunkVerdict", "Docs": "", "Fields": [{ "Name": "Accept", "Docs": "", "Typewords": ["bool"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }, { "Name": "Details", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Classified", "Docs": "", "Typewords": ["bool"] }, { "Name": "Score", "Docs": "", "Typewords": ["float64"] }, { "Name": "Threshold", "Docs": "", "Typewords": ["float64"] }, { "Name": "Significant", "Docs": "", "Typewords": ["bool"] }, { "Name": "HamWords", "Docs": "", "Typewords": ["[]", "MessageJunkWord"] }, { "Name": "SpamWords", "Docs": "", "Typewords": ["[]", "MessageJunkWord"] }] },
+		"MessageJunkWord": { "Name": "MessageJunkWord", "Docs": "", "Fields": [{ "Name": "Word", "Docs": "", "Typewords": ["string"] }, { "Name": "Score", "Docs": "", "Typewords": ["float64"] }] },
+		"ComposeMessage": { "Name": "ComposeMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftID", "Docs": "", "Typewords": ["int64"] }] },
+		"ResponseCompose": { "Name": "ResponseCompose", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "References", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "EditOffset", "Docs": "", "Typewords": ["int32"] }, { "Name": "HTMLBody", "Docs": "", "Typewords": ["string"] }, { "Name": "ForwardAttachments", "Docs": "", "Typewords": ["ForwardAttachments"] }] },
 		"ForwardAttachments": { "Name": "ForwardAttachments", "Docs": "", "Fields": [{ "Name": "MessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Paths", "Docs": "", "Typewords": ["[]", "[]", "int32"] }] },
+		"SubmitMessage": { "Name": "SubmitMessage", "Docs": "", "Fields": [{ "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Cc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Bcc", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "TextBody", "Docs": "", "Typewords": ["string"] }, { "Name": "HTMLBody", "Docs": "", "Typewords": ["string"] }, { "Name": "InlineFiles", "Docs": "", "Typewords": ["[]", "InlineFile"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "File"] }, { "Name": "ForwardAttachments", "Docs": "", "Typewords": ["ForwardAttachments"] }, { "Name": "AttachMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ResponseMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UserAgent", "Docs": "", "Typewords": ["string"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "FutureRelease", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "ArchiveThread", "Docs": "", "Typewords": ["bool"] }, { "Name": "ArchiveReferenceMailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "DraftID", "Docs": "", "Typewords": ["int64"] }, { "Name": "PGPSign", "Docs": "", "Typewords": ["bool"] }, { "Name": "PGPEncrypt", "Docs": "", "Typewords": ["bool"] }, { "Name": "PGPPassphrase", "Docs": "", "Typewords": ["string"] }] },
+		"InlineFile": { "Name": "InlineFile", "Docs": "", "Fields": [{ "Name": "ContentID", "Docs": "", "Typewords": ["string"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"File": { "Name": "File", "Docs": "", "Fields": [{ "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"SubmitResult": { "Name": "SubmitResult", "Docs": "", "Fields": [{ "Name": "QueueMsgIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "SentMessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UndoUntil", "Docs": "", "Typewords": ["nullable", "timestamp"] }] },
+		"PGPResult": { "Name": "PGPResult", "Docs": "", "Fields": [{ "Name": "Decrypted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Signed", "Docs": "", "Typewords": ["bool"] }, { "Name": "SignatureValid", "Docs": "", "Typewords": ["bool"] }, { "Name": "SignatureError", "Docs": "", "Typewords": ["string"] }, { "Name": "SignerKeyID", "Docs": "", "Typewords": ["string"] }, { "Name": "SignerAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignerFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "SignerKeySource", "Docs": "", "Typewords": ["string"] }, { "Name": "Texts", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Unsubscribe": { "Name": "Unsubscribe", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Time", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "FromAddress", "Docs": "", "Typewords": ["string"] }, { "Name": "ListID", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Method", "Docs": "", "Typewords": ["string"] }, { "Name": "Target", "Docs": "", "Typewords": ["string"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"BulkAction": { "Name": "BulkAction", "Docs": "", "Fields": [{ "Name": "Op", "Docs": "", "Typewords": ["BulkOp"] }, { "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Label", "Docs": "", "Typewords": ["string"] }] },
 		"Mailbox": { "Name": "Mailbox", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "CreateSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Expunged", "Docs": "", "Typewords": ["bool"] }, { "Name": "ParentID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "UIDValidity", "Docs": "", "Typewords": ["uint32"] }, { "Name": "UIDNext", "Docs": "", "Typewords": ["UID"] }, { "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Sent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Trash", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HaveCounts", "Docs": "", "Typewords": ["bool"] }, { "Name": "Total", "Docs": "", "Typewords": ["int64"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["int64"] }, { "Name": "Unread", "Docs": "", "Typewords": ["int64"] }, { "Name": "Unseen", "Docs": "", "Typewords": ["int64"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }] },
+		"ThreadSummary": { "Name": "ThreadSummary", "Docs": "", "Fields": [{ "Name": "ThreadID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "MailboxIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "Unread", "Docs": "", "Typewords": ["int32"] }, { "Name": "Participants", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Snippet", "Docs": "", "Typewords": ["string"] }, { "Name": "Latest", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Muted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Collapsed", "Docs": "", "Typewords": ["bool"] }] },
 		"RecipientSecurity": { "Name": "RecipientSecurity", "Docs": "", "Fields": [{ "Name": "STARTTLS", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "MTASTS", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "DNSSEC", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "DANE", "Docs": "", "Typewords": ["SecurityResult"] }, { "Name": "RequireTLS", "Docs": "", "Typewords": ["SecurityResult"] }] },
-		"Settings": { "Name": "Settings", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["uint8"] }, { "Name": "Signature", "Docs": "", "Typewords": ["string"] }, { "Name": "Quoting", "Docs": "", "Typewords": ["Quoting"] }, { "Name": "ShowAddressSecurity", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoShowShortcuts", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHeaders", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Settings": { "Name": "Settings", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["uint8"] }, { "Name": "Signature", "Docs": "", "Typewords": ["string"] }, { "Name": "Quoting", "Docs": "", "Typewords": ["Quoting"] }, { "Name": "ShowAddressSecurity", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHTML", "Docs": "", "Typewords": ["bool"] }, { "Name": "NoShowShortcuts", "Docs": "", "Typewords": ["bool"] }, { "Name": "ShowHeaders", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "UndoSendSeconds", "Docs": "", "Typewords": ["int32"] }, { "Name": "HTMLAllowedTags", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "HTMLAllowedAttributes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "RemoteContentProxy", "Docs": "", "Typewords": ["bool"] }, { "Name": "Labels", "Docs": "", "Typewords": ["[]", "Label"] }, { "Name": "NotifyMailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "NotifySenders", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"Label": { "Name": "Label", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Color", "Docs": "", "Typewords": ["string"] }] },
+		"Template": { "Name": "Template", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "TemplateAttachment"] }] },
+		"TemplateAttachment": { "Name": "TemplateAttachment", "Docs": "", "Fields": [{ "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "DataURI", "Docs": "", "Typewords": ["string"] }] },
+		"SharedTemplate": { "Name": "SharedTemplate", "Docs": "", "Fields": [{ "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }] },
+		"TemplateRef": { "Name": "TemplateRef", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }] },
+		"ComposeTemplate": { "Name": "ComposeTemplate", "Docs": "", "Fields": [{ "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "File"] }] },
 		"Ruleset": { "Name": "Ruleset", "Docs": "", "Fields": [{ "Name": "SMTPMailFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromRegexp", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "HeadersRegexp", "Docs": "", "Typewords": ["{}", "string"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListAllowDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "AcceptRejectsToMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Comment", "Docs": "", "Typewords": ["string"] }, { "Name": "VerifiedDNSDomain", "Docs": "", "Typewords": ["Domain"] }, { "Name": "ListAllowDNSDomain", "Docs": "", "Typewords": ["Domain"] }] },
-		"EventStart": { "Name": "EventStart", "Docs": "", "Fields": [{ "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["MessageAddress"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "DomainAddressConfigs", "Docs": "", "Typewords": ["{}", "DomainAddressConfig"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "Mailbox"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Settings", "Docs": "", "Typewords": ["Settings"] }, { "Name": "AccountPath", "Docs": "", "Typewords": ["string"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }] },
+		"Rule": { "Name": "Rule", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Position", "Docs": "", "Typewords": ["int32"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Disabled", "Docs": "", "Typewords": ["bool"] }, { "Name": "From", "Docs": "", "Typewords": ["string"] }, { "Name": "To", "Docs": "", "Typewords": ["string"] }, { "Name": "SubjectContains", "Docs": "", "Typewords": ["string"] }, { "Name": "HasAttachment", "Docs": "", "Typewords": ["bool"] }, { "Name": "ListID", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "MarkRead", "Docs": "", "Typewords": ["bool"] }, { "Name": "ForwardTo", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Delete", "Docs": "", "Typewords": ["bool"] }, { "Name": "Stop", "Docs": "", "Typewords": ["bool"] }] },
+		"SavedSearch": { "Name": "SavedSearch", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Search", "Docs": "", "Typewords": ["string"] }, { "Name": "Filter", "Docs": "", "Typewords": ["Filter"] }, { "Name": "NotFilter", "Docs": "", "Typewords": ["NotFilter"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }, { "Name": "Unseen", "Docs": "", "Typewords": ["int32"] }] },
+		"Draft": { "Name": "Draft", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Version", "Docs": "", "Typewords": ["int64"] }, { "Name": "Updated", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Message", "Docs": "", "Typewords": ["ComposeMessage"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "DraftAttachment"] }] },
+		"DraftAttachment": { "Name": "DraftAttachment", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "ContentType", "Docs": "", "Typewords": ["string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }] },
+		"Delegation": { "Name": "Delegation", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Write", "Docs": "", "Typewords": ["bool"] }, { "Name": "Send", "Docs": "", "Typewords": ["string"] }] },
+		"DelegatedAccess": { "Name": "DelegatedAccess", "Docs": "", "Fields": [{ "Name": "Account", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Write", "Docs": "", "Typewords": ["bool"] }, { "Name": "Send", "Docs": "", "Typewords": ["string"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"MessageItem": { "Name": "MessageItem", "Docs": "", "Fields": [{ "Name": "Message", "Docs": "", "Typewords": ["Message"] }, { "Name": "Envelope", "Docs": "", "Typewords": ["MessageEnvelope"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "Attachment"] }, { "Name": "IsSigned", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsEncrypted", "Docs": "", "Typewords": ["bool"] }, { "Name": "SMIME", "Docs": "", "Typewords": ["nullable", "SMIMEStatus"] }, { "Name": "Calendar", "Docs": "", "Typewords": ["nullable", "CalendarInvite"] }, { "Name": "Unsubscribe", "Docs": "", "Typewords": ["nullable", "ListUnsubscribe"] }, { "Name": "Auth", "Docs": "", "Typewords": ["nullable", "AuthResults"] }, { "Name": "MatchQuery", "Docs": "", "Typewords": ["bool"] }, { "Name": "MoreHeaders", "Docs": "", "Typewords": ["[]", "[]", "string"] }, { "Name": "SenderWarnings", "Docs": "", "Typewords": ["[]", "SenderWarning"] }] },
+		"Message": { "Name": "Message", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UID", "Docs": "", "Typewords": ["UID"] }, { "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "CreateSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Expunged", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsReject", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxOrigID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxDestinedID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SaveDate", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked1", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked2", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked3", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MailFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "RcptToLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RcptToDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MsgFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromOrgDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLOValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MsgFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "EHLOValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MailFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MsgFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "DKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "OrigEHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "OrigDKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Auth", "Docs": "", "Typewords": ["nullable", "MessageAuth"] }, { "Name": "ReputationJunk", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "SubjectBase", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageHash", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ThreadID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ThreadParentIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "ThreadMissingLink", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadMuted", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadCollapsed", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsMailingList", "Docs": "", "Typewords": ["bool"] }, { "Name": "DSN", "Docs": "", "Typewords": ["bool"] }, { "Name": "ReceivedTLSVersion", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedTLSCipherSuite", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedRequireTLS", "Docs": "", "Typewords": ["bool"] }, { "Name": "Seen", "Docs": "", "Typewords": ["bool"] }, { "Name": "Answered", "Docs": "", "Typewords": ["bool"] }, { "Name": "Flagged", "Docs": "", "Typewords": ["bool"] }, { "Name": "Forwarded", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Phishing", "Docs": "", "Typewords": ["bool"] }, { "Name": "MDNSent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "TrainedJunk", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "StoredSize", "Docs": "", "Typewords": ["int64"] }, { "Name": "Compressed", "Docs": "", "Typewords": ["bool"] }, { "Name": "Preview", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ParsedBuf", "Docs": "", "Typewords": ["nullable", "string"] }] },
+		"MessageAuth": { "Name": "MessageAuth", "Docs": "", "Fields": [{ "Name": "IPRev", "Docs": "", "Typewords": ["string"] }, { "Name": "IPRevAuthentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "SPF", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFIdentity", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "SPFAuthentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["[]", "MessageAuthDKIM"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["string"] }, { "Name": "DMARCDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "DMARCPolicy", "Docs": "", "Typewords": ["string"] }, { "Name": "DMARCAuthentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "DMARCOverrides", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "ARCSealer", "Docs": "", "Typewords": ["string"] }, { "Name": "BIMI", "Docs": "", "Typewords": ["string"] }, { "Name": "BIMIDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "DNSBLs", "Docs": "", "Typewords": ["[]", "MessageAuthDNSBL"] }] },
+		"MessageAuthDKIM": { "Name": "MessageAuthDKIM", "Docs": "", "Fields": [{ "Name": "Status", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }, { "Name": "Selector", "Docs": "", "Typewords": ["string"] }, { "Name": "Algorithm", "Docs": "", "Typewords": ["string"] }, { "Name": "Identity", "Docs": "", "Typewords": ["string"] }, { "Name": "Authentic", "Docs": "", "Typewords": ["bool"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }] },
+		"MessageAuthDNSBL": { "Name": "MessageAuthDNSBL", "Docs": "", "Fields": [{ "Name": "Zone", "Docs": "", "Typewords": ["string"] }, { "Name": "Status", "Docs": "", "Typewords": ["string"] }] },
+		"MessageEnvelope": { "Name": "MessageEnvelope", "Docs": "", "Fields": [{ "Name": "Date", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "Sender", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }] },
+		"Attachment": { "Name": "Attachment", "Docs": "", "Fields": [{ "Name": "Path", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }] },
+		"SMIMEStatus": { "Name": "SMIMEStatus", "Docs": "", "Fields": [{ "Name": "Valid", "Docs": "", "Typewords": ["bool"] }, { "Name": "Error", "Docs": "", "Typewords": ["string"] }, { "Name": "SignerAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignerFrom", "Docs": "", "Typewords": ["bool"] }, { "Name": "Fingerprint", "Docs": "", "Typewords": ["string"] }] },
+		"CalendarInvite": { "Name": "CalendarInvite", "Docs": "", "Fields": [{ "Name": "Path", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "Method", "Docs": "", "Typewords": ["string"] }, { "Name": "UID", "Docs": "", "Typewords": ["string"] }, { "Name": "Sequence", "Docs": "", "Typewords": ["int32"] }, { "Name": "Summary", "Docs": "", "Typewords": ["string"] }, { "Name": "Location", "Docs": "", "Typewords": ["string"] }, { "Name": "Description", "Docs": "", "Typewords": ["string"] }, { "Name": "Start", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "End", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "AllDay", "Docs": "", "Typewords": ["bool"] }, { "Name": "Organizer", "Docs": "", "Typewords": ["CalendarAttendee"] }, { "Name": "Attendees", "Docs": "", "Typewords": ["[]", "CalendarAttendee"] }] },
+		"CalendarAttendee": { "Name": "CalendarAttendee", "Docs": "", "Fields": [{ "Name": "Name", "Docs": "", "Typewords": ["string"] }, { "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "Status", "Docs": "", "Typewords": ["string"] }, { "Name": "Role", "Docs": "", "Typewords": ["string"] }] },
+		"ListUnsubscribe": { "Name": "ListUnsubscribe", "Docs": "", "Fields": [{ "Name": "URL", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailto", "Docs": "", "Typewords": ["string"] }, { "Name": "OneClick", "Docs": "", "Typewords": ["bool"] }] },
+		"AuthResults": { "Name": "AuthResults", "Docs": "", "Fields": [{ "Name": "IPRev", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "SPF", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "DKIM", "Docs": "", "Typewords": ["[]", "AuthCheck"] }, { "Name": "DMARC", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "BIMI", "Docs": "", "Typewords": ["AuthCheck"] }, { "Name": "BIMILogoURL", "Docs": "", "Typewords": ["string"] }, { "Name": "BIMILogoPath", "Docs": "", "Typewords": ["string"] }, { "Name": "Reason", "Docs": "", "Typewords": ["string"] }, { "Name": "ReasonDetails", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"AuthCheck": { "Name": "AuthCheck", "Docs": "", "Fields": [{ "Name": "Result", "Docs": "", "Typewords": ["string"] }, { "Name": "Domain", "Docs": "", "Typewords": ["string"] }] },
+		"SenderWarning": { "Name": "SenderWarning", "Docs": "", "Fields": [{ "Name": "Kind", "Docs": "", "Typewords": ["SenderWarningKind"] }, { "Name": "Similar", "Docs": "", "Typewords": ["string"] }, { "Name": "Text", "Docs": "", "Typewords": ["string"] }] },
+		"EventStart": { "Name": "EventStart", "Docs": "", "Fields": [{ "Name": "SSEID", "Docs": "", "Typewords": ["int64"] }, { "Name": "LoginAddress", "Docs": "", "Typewords": ["MessageAddress"] }, { "Name": "Addresses", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "DomainAddressConfigs", "Docs": "", "Typewords": ["{}", "DomainAddressConfig"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Mailboxes", "Docs": "", "Typewords": ["[]", "Mailbox"] }, { "Name": "RejectsMailbox", "Docs": "", "Typewords": ["string"] }, { "Name": "Settings", "Docs": "", "Typewords": ["Settings"] }, { "Name": "Identities", "Docs": "", "Typewords": ["[]", "Identity"] }, { "Name": "PGPAddresses", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Delegated", "Docs": "", "Typewords": ["[]", "DelegatedAccess"] }, { "Name": "SavedSearches", "Docs": "", "Typewords": ["[]", "SavedSearch"] }, { "Name": "AccountPath", "Docs": "", "Typewords": ["string"] }, { "Name": "QuotaWarning", "Docs": "", "Typewords": ["string"] }, { "Name": "Impersonation", "Docs": "", "Typewords": ["nullable", "Impersonation"] }, { "Name": "Version", "Docs": "", "Typewords": ["string"] }, { "Name": "SearchIndexSalt", "Docs": "", "Typewords": ["string"] }, { "Name": "Resumed", "Docs": "", "Typewords": ["bool"] }] },
 		"DomainAddressConfig": { "Name": "DomainAddressConfig", "Docs": "", "Fields": [{ "Name": "LocalpartCatchallSeparators", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "LocalpartCaseSensitive", "Docs": "", "Typewords": ["bool"] }] },
+		"Identity": { "Name": "Identity", "Docs": "", "Fields": [{ "Name": "Address", "Docs": "", "Typewords": ["string"] }, { "Name": "DisplayName", "Docs": "", "Typewords": ["string"] }, { "Name": "Signature", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "SignatureHTML", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyQuoting", "Docs": "", "Typewords": ["string"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "Default", "Docs": "", "Typewords": ["bool"] }] },
+		"Impersonation": { "Name": "Impersonation", "Docs": "", "Fields": [{ "Name": "By", "Docs": "", "Typewords": ["string"] }, { "Name": "ReadOnly", "Docs": "", "Typewords": ["bool"] }, { "Name": "End", "Docs": "", "Typewords": ["timestamp"] }] },
 		"EventViewErr": { "Name": "EventViewErr", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RequestID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Err", "Docs": "", "Typewords": ["string"] }] },
 		"EventViewReset": { "Name": "EventViewReset", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RequestID", "Docs": "", "Typewords": ["int64"] }] },
 		"EventViewMsgs": { "Name": "EventViewMsgs", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "RequestID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MessageItems", "Docs": "", "Typewords": ["[]", "[]", "MessageItem"] }, { "Name": "ParsedMessage", "Docs": "", "Typewords": ["nullable", "ParsedMessage"] }, { "Name": "ViewEnd", "Docs": "", "Typewords": ["bool"] }] },
-		"MessageItem": { "Name": "MessageItem", "Docs": "", "Fields": [{ "Name": "Message", "Docs": "", "Typewords": ["Message"] }, { "Name": "Envelope", "Docs": "", "Typewords": ["MessageEnvelope"] }, { "Name": "Attachments", "Docs": "", "Typewords": ["[]", "Attachment"] }, { "Name": "IsSigned", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsEncrypted", "Docs": "", "Typewords": ["bool"] }, { "Name": "MatchQuery", "Docs": "", "Typewords": ["bool"] }, { "Name": "MoreHeaders", "Docs": "", "Typewords": ["[]", "[]", "string"] }] },
-		"Message": { "Name": "Message", "Docs": "", "Fields": [{ "Name": "ID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UID", "Docs": "", "Typewords": ["UID"] }, { "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "CreateSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Expunged", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsReject", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsForward", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailboxOrigID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxDestinedID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Received", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "SaveDate", "Docs": "", "Typewords": ["nullable", "timestamp"] }, { "Name": "RemoteIP", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked1", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked2", "Docs": "", "Typewords": ["string"] }, { "Name": "RemoteIPMasked3", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFrom", "Docs": "", "Typewords": ["string"] }, { "Name": "MailFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MailFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "RcptToLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "RcptToDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromLocalpart", "Docs": "", "Typewords": ["Localpart"] }, { "Name": "MsgFromDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "MsgFromOrgDomain", "Docs": "", "Typewords": ["string"] }, { "Name": "EHLOValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MailFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "MsgFromValidated", "Docs": "", "Typewords": ["bool"] }, { "Name": "EHLOValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MailFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "MsgFromValidation", "Docs": "", "Typewords": ["Validation"] }, { "Name": "DKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "OrigEHLODomain", "Docs": "", "Typewords": ["string"] }, { "Name": "OrigDKIMDomains", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }, { "Name": "SubjectBase", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageHash", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ThreadID", "Docs": "", "Typewords": ["int64"] }, { "Name": "ThreadParentIDs", "Docs": "", "Typewords": ["[]", "int64"] }, { "Name": "ThreadMissingLink", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadMuted", "Docs": "", "Typewords": ["bool"] }, { "Name": "ThreadCollapsed", "Docs": "", "Typewords": ["bool"] }, { "Name": "IsMailingList", "Docs": "", "Typewords": ["bool"] }, { "Name": "DSN", "Docs": "", "Typewords": ["bool"] }, { "Name": "ReceivedTLSVersion", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedTLSCipherSuite", "Docs": "", "Typewords": ["uint16"] }, { "Name": "ReceivedRequireTLS", "Docs": "", "Typewords": ["bool"] }, { "Name": "Seen", "Docs": "", "Typewords": ["bool"] }, { "Name": "Answered", "Docs": "", "Typewords": ["bool"] }, { "Name": "Flagged", "Docs": "", "Typewords": ["bool"] }, { "Name": "Forwarded", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Phishing", "Docs": "", "Typewords": ["bool"] }, { "Name": "MDNSent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "Size", "Docs": "", "Typewords": ["int64"] }, { "Name": "TrainedJunk", "Docs": "", "Typewords": ["nullable", "bool"] }, { "Name": "MsgPrefix", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "Preview", "Docs": "", "Typewords": ["nullable", "string"] }, { "Name": "ParsedBuf", "Docs": "", "Typewords": ["nullable", "string"] }] },
-		"MessageEnvelope": { "Name": "MessageEnvelope", "Docs": "", "Fields": [{ "Name": "Date", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "Subject", "Docs": "", "Typewords": ["string"] }, { "Name": "From", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "Sender", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "ReplyTo", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "To", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "CC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "BCC", "Docs": "", "Typewords": ["[]", "MessageAddress"] }, { "Name": "InReplyTo", "Docs": "", "Typewords": ["string"] }, { "Name": "MessageID", "Docs": "", "Typewords": ["string"] }] },
-		"Attachment": { "Name": "Attachment", "Docs": "", "Fields": [{ "Name": "Path", "Docs": "", "Typewords": ["[]", "int32"] }, { "Name": "Filename", "Docs": "", "Typewords": ["string"] }, { "Name": "Part", "Docs": "", "Typewords": ["Part"] }] },
 		"EventViewChanges": { "Name": "EventViewChanges", "Docs": "", "Fields": [{ "Name": "ViewID", "Docs": "", "Typewords": ["int64"] }, { "Name": "Changes", "Docs": "", "Typewords": ["[]", "[]", "any"] }] },
 		"ChangeMsgAdd": { "Name": "ChangeMsgAdd", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "UID", "Docs": "", "Typewords": ["UID"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }, { "Name": "Flags", "Docs": "", "Typewords": ["Flags"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }, { "Name": "MessageCountIMAP", "Docs": "", "Typewords": ["uint32"] }, { "Name": "Unseen", "Docs": "", "Typewords": ["uint32"] }, { "Name": "MessageItems", "Docs": "", "Typewords": ["[]", "MessageItem"] }] },
 		"Flags": { "Name": "Flags", "Docs": "", "Fields": [{ "Name": "Seen", "Docs": "", "Typewords": ["bool"] }, { "Name": "Answered", "Docs": "", "Typewords": ["bool"] }, { "Name": "Flagged", "Docs": "", "Typewords": ["bool"] }, { "Name": "Forwarded", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Notjunk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Deleted", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Phishing", "Docs": "", "Typewords": ["bool"] }, { "Name": "MDNSent", "Docs": "", "Typewords": ["bool"] }] },
@@ -335,6 +395,9 @@ var api;
 		"ChangeMailboxSpecialUse": { "Name": "ChangeMailboxSpecialUse", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "SpecialUse", "Docs": "", "Typewords": ["SpecialUse"] }, { "Name": "ModSeq", "Docs": "", "Typewords": ["ModSeq"] }] },
 		"SpecialUse": { "Name": "SpecialUse", "Docs": "", "Fields": [{ "Name": "Archive", "Docs": "", "Typewords": ["bool"] }, { "Name": "Draft", "Docs": "", "Typewords": ["bool"] }, { "Name": "Junk", "Docs": "", "Typewords": ["bool"] }, { "Name": "Sent", "Docs": "", "Typewords": ["bool"] }, { "Name": "Trash", "Docs": "", "Typewords": ["bool"] }] },
 		"ChangeMailboxKeywords": { "Name": "ChangeMailboxKeywords", "Docs": "", "Fields": [{ "Name": "MailboxID", "Docs": "", "Typewords": ["int64"] }, { "Name": "MailboxName", "Docs": "", "Typewords": ["string"] }, { "Name": "Keywords", "Docs": "", "Typewords": ["[]", "string"] }] },
+		"EventSavedSearches": { "Name": "EventSavedSearches", "Docs": "", "Fields": [{ "Name": "SavedSearches", "Docs": "", "Typewords": ["[]", "SavedSearch"] }] },
+		"EventBulkProgress": { "Name": "EventBulkProgress", "Docs": "", "Fields": [{ "Name": "Op", "Docs": "", "Typewords": ["BulkOp"] }, { "Name": "Done", "Docs": "", "Typewords": ["int32"] }, { "Name": "Total", "Docs": "", "Typewords": ["int32"] }] },
+		"ViewResume": { "Name": "ViewResume", "Docs": "", "Fields": [{ "Name": "LastEventID", "Docs": "", "Typewords": ["int64"] }, { "Name": "LastMessageReceived", "Docs": "", "Typewords": ["timestamp"] }, { "Name": "ViewEnd", "Docs": "", "Typewords": ["bool"] }] },
 		"ModSeq": { "Name": "ModSeq", "Docs": "", "Values": null },
 		"UID": { "Name": "UID", "Docs": "", "Values": null },
 		"Validation": { "Name": "Validation", "Docs": "", "Values": [{ "Name": "ValidationUnknown", "Value": 0, "Docs": "" }, { "Name": "ValidationStrict", "Value": 1, "Docs": "" }, { "Name": "ValidationDMARC", "Value": 2, "Docs": "" }, { "Name": "ValidationRelaxed", "Value": 3, "Docs": "" }, { "Name": "ValidationPass", "Value": 4, "Docs": "" }, { "Name": "ValidationNeutral", "Value": 5, "Docs": "" }, { "Name": "ValidationTemperror", "Value": 6, "Docs": "" }, { "Name": "ValidationPermerror", "Value": 7, "Docs": "" }, { "Name": "ValidationFail", "Value": 8, "Docs": "" }, { "Name": "ValidationSoftfail", "Value": 9, "Docs": "" }, { "Name": "ValidationNone", "Value": 10, "Docs": "" }] },
@@ -342,11 +405,16 @@ var api;
 		"ThreadMode": { "Name": "ThreadMode", "Docs": "", "Values": [{ "Name": "ThreadOff", "Value": "off", "Docs": "" }, { "Name": "ThreadOn", "Value": "on", "Docs": "" }, { "Name": "ThreadUnread", "Value": "unread", "Docs": "" }] },
 		"AttachmentType": { "Name": "AttachmentType", "Docs": "", "Values": [{ "Name": "AttachmentIndifferent", "Value": "", "Docs": "" }, { "Name": "AttachmentNone", "Value": "none", "Docs": "" }, { "Name": "AttachmentAny", "Value": "any", "Docs": "" }, { "Name": "AttachmentImage", "Value": "image", "Docs": "" }, { "Name": "AttachmentPDF", "Value": "pdf", "Docs": "" }, { "Name": "AttachmentArchive", "Value": "archive", "Docs": "" }, { "Name": "AttachmentSpreadsheet", "Value": "spreadsheet", "Docs": "" }, { "Name": "AttachmentDocument", "Value": "document", "Docs": "" }, { "Name": "AttachmentPresentation", "Value": "presentation", "Docs": "" }] },
 		"ViewMode": { "Name": "ViewMode", "Docs": "", "Values": [{ "Name": "ModeText", "Value": "text", "Docs": "" }, { "Name": "ModeHTML", "Value": "html", "Docs": "" }, { "Name": "ModeHTMLExt", "Value": "htmlext", "Docs": "" }] },
+		"ResponseMode": { "Name": "ResponseMode", "Docs": "", "Values": [{ "Name": "ResponseReply", "Value": "reply", "Docs": "" }, { "Name": "ResponseReplyAll", "Value": "replyall", "Docs": "" }, { "Name": "ResponseReplyList", "Value": "replylist", "Docs": "" }, { "Name": "ResponseForward", "Value": "forward", "Docs": "" }] },
+		"BulkOp": { "Name": "BulkOp", "Docs": "", "Values": [{ "Name": "BulkMarkRead", "Value": "markread", "Docs": "" }, { "Name": "BulkMarkUnread", "Value": "markunread", "Docs": "" }, { "Name": "BulkMove", "Value": "move", "Docs": "" }, { "Name": "BulkLabel", "Value": "label", "Docs": "" }, { "Name": "BulkDelete", "Value": "delete", "Docs": "" }] },
 		"SecurityResult": { "Name": "SecurityResult", "Docs": "", "Values": [{ "Name": "SecurityResultError", "Value": "error", "Docs": "" }, { "Name": "SecurityResultNo", "Value": "no", "Docs": "" }, { "Name": "SecurityResultYes", "Value": "yes", "Docs": "" }, { "Name": "SecurityResultUnknown", "Value": "unknown", "Docs": "" }] },
 		"Quoting": { "Name": "Quoting", "Docs": "", "Values": [{ "Name": "Default", "Value": "", "Docs": "" }, { "Name": "Bottom", "Value": "bottom", "Docs": "" }, { "Name": "Top", "Value": "top", "Docs": "" }] },
 		"Localpart": { "Name": "Localpart", "Docs": "", "Values": null },
+		"SenderWarningKind": { "Name": "SenderWarningKind", "Docs": "", "Values": [{ "Name": "SenderWarningDisplayName", "Value": "displayname", "Docs": "" }, { "Name": "SenderWarningLookalike", "Value": "lookalike", "Docs": "" }, { "Name": "SenderWarningReplyTo", "Value": "replyto", "Docs": "" }] },
 	};
 	api.parser = {
+		PasskeyGetOptions: (v) => api.parse("PasskeyGetOptions", v),
+		PasskeyAssertion: (v) => api.parse("PasskeyAssertion", v),
 		Request: (v) => api.parse("Request", v),
 		Query: (v) => api.parse("Query", v),
 		Filter: (v) => api.parse("Filter", v),
@@ -358,24 +426,58 @@ var api;
 		Address: (v) => api.parse("Address", v),
 		MessageAddress: (v) => api.parse("MessageAddress", v),
 		Domain: (v) => api.parse("Domain", v),
+		SMIMECertificate: (v) => api.parse("SMIMECertificate", v),
 		FromAddressSettings: (v) => api.parse("FromAddressSettings", v),
+		MessageJunkVerdict: (v) => api.parse("MessageJunkVerdict", v),
+		MessageJunkWord: (v) => api.parse("MessageJunkWord", v),
 		ComposeMessage: (v) => api.parse("ComposeMessage", v),
+		ResponseCompose: (v) => api.parse("ResponseCompose", v),
+		ForwardAttachments: (v) => api.parse("ForwardAttachments", v),
 		SubmitMessage: (v) => api.parse("SubmitMessage", v),
+		InlineFile: (v) => api.parse("InlineFile", v),
 		File: (v) => api.parse("File", v),
-		ForwardAttachments: (v) => api.parse("ForwardAttachments", v),
+		SubmitResult: (v) => api.parse("SubmitResult", v),
+		PGPResult: (v) => api.parse("PGPResult", v),
+		Unsubscribe: (v) => api.parse("Unsubscribe", v),
+		BulkAction: (v) => api.parse("BulkAction", v),
 		Mailbox: (v) => api.parse("Mailbox", v),
+		ThreadSummary: (v) => api.parse("ThreadSummary", v),
 		RecipientSecurity: (v) => api.parse("RecipientSecurity", v),
 		Settings: (v) => api.parse("Settings", v),
+		Label: (v) => api.parse("Label", v),
+		Template: (v) => api.parse("Template", v),
+		TemplateAttachment: (v) => api.parse("TemplateAttachment", v),
+		SharedTemplate: (v) => api.parse("SharedTemplate", v),
+		TemplateRef: (v) => api.parse("TemplateRef", v),
+		ComposeTemplate: (v) => api.parse("ComposeTemplate", v),
 		Ruleset: (v) => api.parse("Ruleset", v),
+		Rule: (v) => api.parse("Rule", v),
+		SavedSearch: (v) => api.parse("SavedSearch", v),
+		Draft: (v) => api.parse("Draft", v),
+		DraftAttachment: (v) => api.parse("DraftAttachment", v),
+		Delegation: (v) => api.parse("Delegation", v),
+		DelegatedAccess: (v) => api.parse("DelegatedAccess", v),
+		MessageItem: (v) => api.parse("MessageItem", v),
+		Message: (v) => api.parse("Message", v),
+		MessageAuth: (v) => api.parse("MessageAuth", v),
+		MessageAuthDKIM: (v) => api.parse("MessageAuthDKIM", v),
+		MessageAuthDNSBL: (v) => api.parse("MessageAuthDNSBL", v),
+		MessageEnvelope: (v) => api.parse("MessageEnvelope", v),
+		Attachment: (v) => api.parse("Attachment", v),
+		SMIMEStatus: (v) => api.parse("SMIMEStatus", v),
+		CalendarInvite: (v) => api.parse("CalendarInvite", v),
+		CalendarAttendee: (v) => api.parse("CalendarAttendee", v),
+		ListUnsubscribe: (v) => api.parse("ListUnsubscribe", v),
+		AuthResults: (v) => api.parse("AuthResults", v),
+		AuthCheck: (v) => api.parse("AuthCheck", v),
+		SenderWarning: (v) => api.parse("SenderWarning", v),
 		EventStart: (v) => api.parse("EventStart", v),
 		DomainAddressConfig: (v) => api.parse("DomainAddressConfig", v),
+		Identity: (v) => api.parse("Identity", v),
+		Impersonation: (v) => api.parse("Impersonation", v),
 		EventViewErr: (v) => api.parse("EventViewErr", v),
 		EventViewReset: (v) => api.parse("EventViewReset", v),
 		EventViewMsgs: (v) => api.parse("EventViewMsgs", v),
-		MessageItem: (v) => api.parse("MessageItem", v),
-		Message: (v) => api.parse("Message", v),
-		MessageEnvelope: (v) => api.parse("MessageEnvelope", v),
-		Attachment: (v) => api.parse("Attachment", v),
 		EventViewChanges: (v) => api.parse("EventViewChanges", v),
 		ChangeMsgAdd: (v) => api.parse("ChangeMsgAdd", v),
 		Flags: (v) => api.parse("Flags", v),
@@ -389,6 +491,9 @@ var api;
 		ChangeMailboxSpecialUse: (v) => api.parse("ChangeMailboxSpecialUse", v),
 		SpecialUse: (v) => api.parse("SpecialUse", v),
 		ChangeMailboxKeywords: (v) => api.parse("ChangeMailboxKeywords", v),
+		EventSavedSearches: (v) => api.parse("EventSavedSearches", v),
+		EventBulkProgress: (v) => api.parse("EventBulkProgress", v),
+		ViewResume: (v) => api.parse("ViewResume", v),
 		ModSeq: (v) => api.parse("ModSeq", v),
 		UID: (v) => api.parse("UID", v),
 		Validation: (v) => api.parse("Validation", v),
@@ -396,9 +501,12 @@ var api;
 		ThreadMode: (v) => api.parse("ThreadMode", v),
 		AttachmentType: (v) => api.parse("AttachmentType", v),
 		ViewMode: (v) => api.parse("ViewMode", v),
+		ResponseMode: (v) => api.parse("ResponseMode", v),
+		BulkOp: (v) => api.parse("BulkOp", v),
 		SecurityResult: (v) => api.parse("SecurityResult", v),
 		Quoting: (v) => api.parse("Quoting", v),
 		Localpart: (v) => api.parse("Localpart", v),
+		SenderWarningKind: (v) => api.parse("SenderWarningKind", v),
 	};
 	let defaultOptions = { slicesNullable: true, mapsNullable: true, nullableOptional: true };
 	class Client {
@@ -432,12 +540,44 @@ var api;
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Login returns a session token for the credentials, or fails with error code
-		// "user:badLogin". Call LoginPrep to get a loginToken.
-		async Login(loginToken, username, password) {
+		// "user:badLogin". Call LoginPrep to get a loginToken. If two-factor
+		// authentication is enabled, totp must be a code from the authenticator app,
+		// otherwise the login fails with error code "user:totpRequired".
+		async Login(loginToken, username, password, totp) {
 			const fn = "Login";
-			const paramTypes = [["string"], ["string"], ["string"]];
+			const paramTypes = [["string"], ["string"], ["string"], ["string"]];
+			const returnTypes = [["CSRFToken"]];
+			const params = [loginToken, username, password, totp];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLoginStart returns the parameters for the browser to login with a
+		// passkey. Call LoginPrep to get a loginToken, and PasskeyLogin with the response
+		// of the browser.
+		async PasskeyLoginStart(loginToken) {
+			const fn = "PasskeyLoginStart";
+			const paramTypes = [["string"]];
+			const returnTypes = [["PasskeyGetOptions"]];
+			const params = [loginToken];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// PasskeyLogin returns a session token for a passkey login started with
+		// PasskeyLoginStart, or fails with error code "user:loginFailed". Without
+		// password, the passkey must verify the user, e.g. with a PIN. With username and
+		// password, the passkey is the second factor.
+		async PasskeyLogin(loginToken, username, password, assertion) {
+			const fn = "PasskeyLogin";
+			const paramTypes = [["string"], ["string"], ["string"], ["PasskeyAssertion"]];
 			const returnTypes = [["CSRFToken"]];
-			const params = [loginToken, username, password];
+			const params = [loginToken, username, password, assertion];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// OIDCEnabled returns whether logging in through an OpenID Connect identity
+		// provider is possible, by sending the browser to "oidc/login".
+		async OIDCEnabled() {
+			const fn = "OIDCEnabled";
+			const paramTypes = [];
+			const returnTypes = [["bool"]];
+			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// Logout invalidates the session token.
@@ -496,6 +636,17 @@ var api;
 			const params = [fas];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// SMIMEPin pins the key of the certificate that signed the message for the From
+		// address of the message. Messages from the address signed with another key are
+		// shown with a warning. Only messages with a valid signature by a certificate for
+		// the From address can be pinned.
+		async SMIMEPin(msgID) {
+			const fn = "SMIMEPin";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [msgID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageFindMessageID looks up a message by Message-Id header, and returns the ID
 		// of the message in storage. Used when opening a previously saved draft message
 		// for editing again.
@@ -507,6 +658,17 @@ var api;
 			const params = [messageID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageJunkVerdict returns the outcome of the junk analysis done when the
+		// message was delivered over SMTP, with the signals and junk filter words that
+		// contributed, for explaining why a message was (not) marked as junk. Returns
+		// nil for messages without stored verdict, e.g. sent or imported messages.
+		async MessageJunkVerdict(msgID) {
+			const fn = "MessageJunkVerdict";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["nullable", "MessageJunkVerdict"]];
+			const params = [msgID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageCompose composes a message and saves it to the mailbox. Used for
 		// saving draft messages.
 		async MessageCompose(m, mailboxID) {
@@ -516,6 +678,18 @@ var api;
 			const params = [m, mailboxID];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageResponse returns a prepared reply or forward for a message. If selection
+		// is not empty, it is quoted instead of the text of the message, e.g. text the
+		// user selected. The response is sent with MessageSubmit, with ResponseMessageID
+		// set, and for forwards optionally with the attachments in ForwardAttachments, or
+		// the entire message with AttachMessageID.
+		async MessageResponse(messageID, mode, selection) {
+			const fn = "MessageResponse";
+			const paramTypes = [["int64"], ["ResponseMode"], ["string"]];
+			const returnTypes = [["ResponseCompose"]];
+			const params = [messageID, mode, selection];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageSubmit sends a message by submitting it the outgoing email queue. The
 		// message is sent to all addresses listed in the To, Cc and Bcc addresses, without
 		// Bcc message header.
@@ -523,13 +697,83 @@ var api;
 		// If a Sent mailbox is configured, messages are added to it after submitting
 		// to the delivery queue. If Bcc addresses were present, a header is prepended
 		// to the message stored in the Sent mailbox.
+		// 
+		// If UndoSendSeconds is set in the settings and no FutureRelease is requested,
+		// delivery is held back for that many seconds, during which MessageSubmitUndo
+		// can cancel it.
 		async MessageSubmit(m) {
 			const fn = "MessageSubmit";
 			const paramTypes = [["SubmitMessage"]];
-			const returnTypes = [];
+			const returnTypes = [["SubmitResult"]];
 			const params = [m];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageSubmitUndo cancels delivery of a message submitted with MessageSubmit
+		// that is still held back, see SubmitResult. The copy of the message in the Sent
+		// mailbox, if any, is moved to the Drafts mailbox and marked as draft, so it can
+		// be edited and sent again.
+		async MessageSubmitUndo(queueMsgIDs, sentMessageID) {
+			const fn = "MessageSubmitUndo";
+			const paramTypes = [["[]", "int64"], ["int64"]];
+			const returnTypes = [];
+			const params = [queueMsgIDs, sentMessageID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// MessagePGP verifies the signature of an OpenPGP signed message, or decrypts an
+		// OpenPGP encrypted message and verifies the signature inside it, if any. The
+		// private keys of the account are decrypted with passphrase, it can be empty for
+		// keys without passphrase. Signatures are verified with the keys stored in the
+		// account, and with keys from the Web Key Directory of the From address.
+		async MessagePGP(msgID, passphrase) {
+			const fn = "MessagePGP";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [["PGPResult"]];
+			const params = [msgID, passphrase];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// CalendarReply responds to the meeting invitation in a message, with status
+		// ACCEPTED, TENTATIVE or DECLINED. The reply is sent to the organizer, from the
+		// first attendee address of the account.
+		async CalendarReply(msgID, status) {
+			const fn = "CalendarReply";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [["SubmitResult"]];
+			const params = [msgID, status];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// MessageUnsubscribe unsubscribes from the mailing list of a message with a
+		// List-Unsubscribe header. Method "oneclick" makes an HTTPS POST request to the
+		// URL from the header, RFC 8058. Method "mailto" sends an unsubscribe message to
+		// the address from the header, from the address of the account the message was
+		// sent to. The request is added to the unsubscribe history of the sender, also
+		// when a one-click request fails.
+		async MessageUnsubscribe(msgID, method) {
+			const fn = "MessageUnsubscribe";
+			const paramTypes = [["int64"], ["string"]];
+			const returnTypes = [["Unsubscribe"]];
+			const params = [msgID, method];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// UnsubscribeHistory returns the unsubscribe requests made for messages from an
+		// address, most recent first.
+		async UnsubscribeHistory(fromAddress) {
+			const fn = "UnsubscribeHistory";
+			const paramTypes = [["string"]];
+			const returnTypes = [["[]", "Unsubscribe"]];
+			const params = [fromAddress];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SenderBlock adds the message From address of a message, or its domain if
+		// domain is set, to the sender lists of the account with action "reject",
+		// causing future messages from the sender to be rejected during SMTP. The
+		// pattern of the added entry is returned.
+		async SenderBlock(msgID, domain) {
+			const fn = "SenderBlock";
+			const paramTypes = [["int64"], ["bool"]];
+			const returnTypes = [["string"]];
+			const params = [msgID, domain];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MessageMove moves messages to another mailbox. If the message is already in
 		// the mailbox an error is returned.
 		async MessageMove(messageIDs, mailboxID) {
@@ -573,6 +817,19 @@ var api;
 			const params = [mailboxIDs];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// MessageBulk applies an action to all messages matching the filters of the
+		// query, e.g. the current view of the client, without the client having to
+		// fetch and send all message IDs. Messages are changed in batches, each in its
+		// own transaction. If sseID is nonzero, EventBulkProgress events are sent on the
+		// SSE connection after each batch. Deleting is permanent. The number of messages
+		// that were changed is returned.
+		async MessageBulk(sseID, query, action) {
+			const fn = "MessageBulk";
+			const paramTypes = [["int64"], ["Query"], ["BulkAction"]];
+			const returnTypes = [["int32"]];
+			const params = [sseID, query, action];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// MailboxCreate creates a new mailbox.
 		async MailboxCreate(name) {
 			const fn = "MailboxCreate";
@@ -608,8 +865,9 @@ var api;
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
 		// CompleteRecipient returns autocomplete matches for a recipient, returning the
-		// matches, most recently used first, and whether this is the full list and further
-		// requests for longer prefixes aren't necessary.
+		// matches, most recently used first followed by matching contacts from the
+		// address books, and whether this is the full list and further requests for
+		// longer prefixes aren't necessary.
 		async CompleteRecipient(search) {
 			const fn = "CompleteRecipient";
 			const paramTypes = [["string"]];
@@ -644,6 +902,35 @@ var api;
 			const params = [messageIDs, mute];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Threads returns conversations that have at least one message in the mailbox, or
+		// in any mailbox if mailboxID is 0. Threads are ordered by their most recent
+		// message, newest first. The first offset threads are skipped, at most limit
+		// threads are returned.
+		async Threads(mailboxID, offset, limit) {
+			const fn = "Threads";
+			const paramTypes = [["int64"], ["int32"], ["int32"]];
+			const returnTypes = [["[]", "ThreadSummary"]];
+			const params = [mailboxID, offset, limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ThreadsCollapse sets the collapsed state of all messages in the threads, across
+		// all mailboxes.
+		async ThreadsCollapse(threadIDs, collapse) {
+			const fn = "ThreadsCollapse";
+			const paramTypes = [["[]", "int64"], ["bool"]];
+			const returnTypes = [];
+			const params = [threadIDs, collapse];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// ThreadsMute sets the muted state of all messages in the threads, across all
+		// mailboxes. If threads are muted, they are also marked collapsed.
+		async ThreadsMute(threadIDs, mute) {
+			const fn = "ThreadsMute";
+			const paramTypes = [["[]", "int64"], ["bool"]];
+			const returnTypes = [];
+			const params = [threadIDs, mute];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// RecipientSecurity looks up security properties of the address in the
 		// single-address message addressee (as it appears in a To/Cc/Bcc/etc header).
 		async RecipientSecurity(messageAddressee) {
@@ -669,6 +956,43 @@ var api;
 			const params = [settings];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Templates returns the message templates of the account, and the templates
+		// shared by domains the account has an address in.
+		async Templates() {
+			const fn = "Templates";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Template"], ["[]", "SharedTemplate"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TemplateSave adds a template to the account if its ID is zero, and otherwise
+		// updates the existing template. The saved template is returned.
+		async TemplateSave(t) {
+			const fn = "TemplateSave";
+			const paramTypes = [["Template"]];
+			const returnTypes = [["Template"]];
+			const params = [t];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TemplateRemove removes a template from the account.
+		async TemplateRemove(templateID) {
+			const fn = "TemplateRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [templateID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// TemplateApply returns the subject, text and attachments of a template for
+		// composing a message from fromAddress to recipients "to", with placeholders
+		// replaced. Placeholders for the recipient are replaced with the name and address
+		// of the first recipient.
+		async TemplateApply(ref, fromAddress, to) {
+			const fn = "TemplateApply";
+			const paramTypes = [["TemplateRef"], ["string"], ["[]", "string"]];
+			const returnTypes = [["ComposeTemplate"]];
+			const params = [ref, fromAddress, to];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		async RulesetSuggestMove(msgID, mbSrcID, mbDstID) {
 			const fn = "RulesetSuggestMove";
 			const paramTypes = [["int64"], ["int64"], ["int64"]];
@@ -704,11 +1028,265 @@ var api;
 			const params = [mailboxID, toMailbox];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
+		// Rules returns the message rules of the account, in order of evaluation.
+		async Rules() {
+			const fn = "Rules";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Rule"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RuleSave adds a rule to the account if its ID is zero, and otherwise updates
+		// the existing rule. The saved rule is returned.
+		async RuleSave(r) {
+			const fn = "RuleSave";
+			const paramTypes = [["Rule"]];
+			const returnTypes = [["Rule"]];
+			const params = [r];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RuleRemove removes a rule from the account.
+		async RuleRemove(ruleID) {
+			const fn = "RuleRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [ruleID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// RuleRun applies the enabled rules to the existing messages in a mailbox, moving
+		// messages and marking them read. Messages are not forwarded. The number of
+		// messages that matched a rule is returned.
+		async RuleRun(mailboxID) {
+			const fn = "RuleRun";
+			const paramTypes = [["int64"]];
+			const returnTypes = [["int32"]];
+			const params = [mailboxID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SavedSearches returns the saved searches of the account, with message counts.
+		async SavedSearches() {
+			const fn = "SavedSearches";
+			const paramTypes = [];
+			const returnTypes = [["[]", "SavedSearch"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SavedSearchSave adds a saved search to the account if its ID is zero, and
+		// otherwise updates the existing saved search. The saved search is returned with
+		// message counts.
+		async SavedSearchSave(ss) {
+			const fn = "SavedSearchSave";
+			const paramTypes = [["SavedSearch"]];
+			const returnTypes = [["SavedSearch"]];
+			const params = [ss];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// SavedSearchRemove removes a saved search from the account.
+		async SavedSearchRemove(savedSearchID) {
+			const fn = "SavedSearchRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [savedSearchID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Drafts returns the autosaved drafts of messages being composed, most recently
+		// saved first, e.g. for continuing after the browser was closed. Drafts that
+		// haven't been saved for 30 days are removed.
+		async Drafts() {
+			const fn = "Drafts";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Draft"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftSave autosaves the compose state of a draft. A new draft is created if ID
+		// is 0. For existing drafts, Version must be the version of the last save, which
+		// is incremented, otherwise the draft was changed from another device or browser
+		// window and error code "user:draftConflict" is returned.
+		async DraftSave(d) {
+			const fn = "DraftSave";
+			const paramTypes = [["Draft"]];
+			const returnTypes = [["Draft"]];
+			const params = [d];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftRemove removes a draft and its attachments.
+		async DraftRemove(draftID) {
+			const fn = "DraftRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [draftID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftAttachmentAdd uploads an attachment for a draft, so it doesn't have to be
+		// uploaded again with each save or when sending.
+		async DraftAttachmentAdd(draftID, f) {
+			const fn = "DraftAttachmentAdd";
+			const paramTypes = [["int64"], ["File"]];
+			const returnTypes = [["DraftAttachment"]];
+			const params = [draftID, f];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DraftAttachmentRemove removes an uploaded attachment from a draft.
+		async DraftAttachmentRemove(attachmentID) {
+			const fn = "DraftAttachmentRemove";
+			const paramTypes = [["int64"]];
+			const returnTypes = [];
+			const params = [attachmentID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelSave adds a label to the end of the list of labels, or updates the color
+		// of an existing label. Messages are not changed.
+		async LabelSave(label) {
+			const fn = "LabelSave";
+			const paramTypes = [["Label"]];
+			const returnTypes = [["Label"]];
+			const params = [label];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelRename renames a label, and the labels below it in the hierarchy. The
+		// keywords of affected messages are changed in the same transaction. If a label
+		// with the new name already exists, messages with the old label get the existing
+		// label. The number of changed messages is returned.
+		async LabelRename(oldName, newName) {
+			const fn = "LabelRename";
+			const paramTypes = [["string"], ["string"]];
+			const returnTypes = [["int32"]];
+			const params = [oldName, newName];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelRemove removes a label, and the labels below it in the hierarchy. The
+		// keywords are removed from messages in the same transaction. The number of
+		// changed messages is returned.
+		async LabelRemove(name) {
+			const fn = "LabelRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [["int32"]];
+			const params = [name];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// LabelsOrder sets the display order of labels. Names must be those of all
+		// existing labels.
+		async LabelsOrder(names) {
+			const fn = "LabelsOrder";
+			const paramTypes = [["[]", "string"]];
+			const returnTypes = [];
+			const params = [names];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// WebPushKey returns the public key of the account for Web Push, base64url
+		// encoded, for use as "applicationServerKey" when subscribing in the browser.
+		async WebPushKey() {
+			const fn = "WebPushKey";
+			const paramTypes = [];
+			const returnTypes = [["string"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// WebPushSubscribe adds a Web Push subscription of the browser, or updates the
+		// keys of an existing subscription with the same endpoint. Notifications about
+		// new messages matching the settings are sent to the subscription. The keys are
+		// base64url encoded, as returned by the browser.
+		async WebPushSubscribe(endpoint, p256dh, auth) {
+			const fn = "WebPushSubscribe";
+			const paramTypes = [["string"], ["string"], ["string"]];
+			const returnTypes = [];
+			const params = [endpoint, p256dh, auth];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// WebPushUnsubscribe removes the Web Push subscription with the endpoint.
+		async WebPushUnsubscribe(endpoint) {
+			const fn = "WebPushUnsubscribe";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [endpoint];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// Delegations returns the access to mailboxes the account has given to other
+		// accounts, and the access other accounts have given to the account.
+		async Delegations() {
+			const fn = "Delegations";
+			const paramTypes = [];
+			const returnTypes = [["[]", "Delegation"], ["[]", "DelegatedAccess"]];
+			const params = [];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegationSave gives another account access to mailboxes, replacing access
+		// given earlier to that account.
+		async DelegationSave(d) {
+			const fn = "DelegationSave";
+			const paramTypes = [["Delegation"]];
+			const returnTypes = [];
+			const params = [d];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegationRemove removes access given to another account.
+		async DelegationRemove(account) {
+			const fn = "DelegationRemove";
+			const paramTypes = [["string"]];
+			const returnTypes = [];
+			const params = [account];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedMessages returns messages from a mailbox of another account that gave
+		// access, most recently received first.
+		async DelegatedMessages(owner, mailbox, offset, limit) {
+			const fn = "DelegatedMessages";
+			const paramTypes = [["string"], ["string"], ["int32"], ["int32"]];
+			const returnTypes = [["[]", "MessageItem"]];
+			const params = [owner, mailbox, offset, limit];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedParsedMessage returns a message from a mailbox of another account that
+		// gave access.
+		async DelegatedParsedMessage(owner, msgID) {
+			const fn = "DelegatedParsedMessage";
+			const paramTypes = [["string"], ["int64"]];
+			const returnTypes = [["ParsedMessage"]];
+			const params = [owner, msgID];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedFlagsAdd adds flags to messages in mailboxes of another account that
+		// gave write access.
+		async DelegatedFlagsAdd(owner, messageIDs, flaglist) {
+			const fn = "DelegatedFlagsAdd";
+			const paramTypes = [["string"], ["[]", "int64"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs, flaglist];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedFlagsClear clears flags of messages in mailboxes of another account
+		// that gave write access.
+		async DelegatedFlagsClear(owner, messageIDs, flaglist) {
+			const fn = "DelegatedFlagsClear";
+			const paramTypes = [["string"], ["[]", "int64"], ["[]", "string"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs, flaglist];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedMessageMove moves messages to another mailbox of the delegation by
+		// another account that gave write access.
+		async DelegatedMessageMove(owner, messageIDs, mailbox) {
+			const fn = "DelegatedMessageMove";
+			const paramTypes = [["string"], ["[]", "int64"], ["string"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs, mailbox];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
+		// DelegatedMessageDelete permanently deletes messages in mailboxes of another
+		// account that gave write access.
+		async DelegatedMessageDelete(owner, messageIDs) {
+			const fn = "DelegatedMessageDelete";
+			const paramTypes = [["string"], ["[]", "int64"]];
+			const returnTypes = [];
+			const params = [owner, messageIDs];
+			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
+		}
 		// SSETypes exists to ensure the generated API contains the types, for use in SSE events.
 		async SSETypes() {
 			const fn = "SSETypes";
 			const paramTypes = [];
-			const returnTypes = [["EventStart"], ["EventViewErr"], ["EventViewReset"], ["EventViewMsgs"], ["EventViewChanges"], ["ChangeMsgAdd"], ["ChangeMsgRemove"], ["ChangeMsgFlags"], ["ChangeMsgThread"], ["ChangeMailboxRemove"], ["ChangeMailboxAdd"], ["ChangeMailboxRename"], ["ChangeMailboxCounts"], ["ChangeMailboxSpecialUse"], ["ChangeMailboxKeywords"], ["Flags"]];
+			const returnTypes = [["EventStart"], ["EventViewErr"], ["EventViewReset"], ["EventViewMsgs"], ["EventViewChanges"], ["ChangeMsgAdd"], ["ChangeMsgRemove"], ["ChangeMsgFlags"], ["ChangeMsgThread"], ["ChangeMailboxRemove"], ["ChangeMailboxAdd"], ["ChangeMailboxRename"], ["ChangeMailboxCounts"], ["ChangeMailboxSpecialUse"], ["ChangeMailboxKeywords"], ["EventSavedSearches"], ["EventBulkProgress"], ["ViewResume"], ["Flags"]];
 			const params = [];
 			return await _sherpaCall(this.baseURL, this.authState, { ...this.options }, paramTypes, returnTypes, fn, params);
 		}
@@ -1579,6 +2157,8 @@ const yscrollAutoStyle = css('yscrollAuto', { overflowY: 'auto', position: 'abso
 const autosizeStyle = css('autosize', { display: 'inline-grid', maxWidth: '90vw' });
 ensureCSS('.autosize.input', { gridArea: '1 / 2' });
 ensureCSS('.autosize::after', { content: 'attr(data-value)', marginRight: '1em', lineHeight: 0, visibility: 'hidden', whiteSpace: 'pre-wrap', overflowX: 'hidden' });
+// Per-domain customization, from branding.js.
+const branding = window.moxBranding || {};
 let moxversion;
 let moxgoos;
 let moxgoarch;
@@ -1591,6 +2171,11 @@ try {
 }
 catch (err) { }
 let accountSettings;
+// Style for showing keyword kw, with the color of its label, if configured.
+const keywordColor = (kw) => {
+	const l = (accountSettings?.Labels || []).find(l => l.Name === kw);
+	return l && l.Color ? style({ backgroundColor: l.Color }) : [];
+};
 const defaultSettings = {
 	mailboxesWidth: 240,
 	layout: 'auto',
@@ -1684,8 +2269,29 @@ let loginAddress = null;
 // Localpart config (catchall separator and case sensitivity) for each domain
 // the account has an address for.
 let domainAddressConfigs = {};
+// Identities of the account, with From address, display name and signature. Listed
+// first as From address when composing. Set when SSE connection is initialized.
+let accountIdentities = [];
+// Addresses with an OpenPGP private key in the account, offered for signing and
+// encrypting when composing. Set when SSE connection is initialized.
+let pgpAddresses = [];
+// Base64-encoded salt for hashing search words, for accounts with a hashed
+// full-text search index, see api.EventStart. Remembered for the request made
+// when connecting, before the start event.
+let searchIndexSalt = '';
+try {
+	searchIndexSalt = window.localStorage.getItem('webmailsearchindexsalt') || '';
+}
+catch (err) {
+}
+// Access to mailboxes given to this account by other accounts, for opening their
+// mailboxes and sending messages. Set when SSE connection is initialized.
+let accountDelegated = [];
 // Mailbox containing rejects.
 let rejectsMailbox = '';
+// Whether an admin is acting as the account in a read-only session. Messages are
+// not marked as read automatically.
+let impersonationReadOnly = false;
 // Last known server version. For asking to reload.
 let lastServerVersion = '';
 const login = async (reason) => {
@@ -1697,6 +2303,23 @@ const login = async (reason) => {
 		let autosize;
 		let username;
 		let password;
+		let totpLabel;
+		let totp;
+		let sso;
+		const loggedIn = (token) => {
+			try {
+				window.localStorage.setItem('webmailcsrftoken', token);
+			}
+			catch (err) {
+				console.log('saving csrf token in localStorage', err);
+			}
+			root.remove();
+			if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
+				origFocus.focus();
+			}
+			popupOpen = false;
+			resolve(token);
+		};
 		const root = dom.div(css('loginOverlay', { position: 'absolute', top: 0, right: 0, bottom: 0, left: 0, backgroundColor: styles.overlayOpaqueBackgroundColor, display: 'flex', alignItems: 'center', justifyContent: 'center', zIndex: zindexes.login, animation: 'fadein .15s ease-in' }), dom.div(style({ display: 'flex', flexDirection: 'column', alignItems: 'center' }), reasonElem = reason ? dom.div(css('sessionError', { marginBottom: '2ex', textAlign: 'center' }), reason) : dom.div(), dom.div(css('loginPopup', {
 			backgroundColor: styles.popupBackgroundColor,
 			boxShadow: styles.boxShadow,
@@ -1716,32 +2339,82 @@ const login = async (reason) => {
 			try {
 				fieldset.disabled = true;
 				const loginToken = await client.LoginPrep();
-				const token = await client.Login(loginToken, username.value, password.value);
-				try {
-					window.localStorage.setItem('webmailcsrftoken', token);
-				}
-				catch (err) {
-					console.log('saving csrf token in localStorage', err);
-				}
-				root.remove();
-				if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
-					origFocus.focus();
-				}
-				popupOpen = false;
-				resolve(token);
+				const token = await client.Login(loginToken, username.value, password.value, totp.value);
+				loggedIn(token);
 			}
 			catch (err) {
 				console.log('login error', err);
+				if (err.code === 'user:totpRequired') {
+					// Password is valid, ask for the code from the authenticator app.
+					totpLabel.style.display = 'block';
+					totp.required = true;
+					fieldset.disabled = false;
+					totp.focus();
+					return;
+				}
 				window.alert('Error: ' + errmsg(err));
 			}
 			finally {
 				fieldset.disabled = false;
 			}
-		}, fieldset = dom.fieldset(dom.h1('Mail'), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Email address', style({ marginBottom: '.5ex' })), autosize = dom.span(dom._class('autosize'), username = dom.input(attr.required(''), attr.autocomplete('username'), attr.placeholder('jane@example.org'), function change() { autosize.dataset.value = username.value; }, function input() { autosize.dataset.value = username.value; }))), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Password', style({ marginBottom: '.5ex' })), password = dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required(''))), dom.div(style({ textAlign: 'center' }), dom.submitbutton('Login')))))));
+		}, fieldset = dom.fieldset(dom.h1(branding.Name || 'Mail'), branding.Logo ? dom.div(style({ textAlign: 'center', marginBottom: '2ex' }), dom.img(attr.src('branding/logo'), style({ maxWidth: '20em', maxHeight: '6em' }))) : [], branding.LoginText ? dom.div(style({ marginBottom: '2ex', maxWidth: '30em', whiteSpace: 'pre-wrap' }), branding.LoginText) : [], dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Email address', style({ marginBottom: '.5ex' })), autosize = dom.span(dom._class('autosize'), username = dom.input(attr.required(''), attr.autocomplete('username'), attr.placeholder('jane@example.org'), function change() { autosize.dataset.value = username.value; }, function input() { autosize.dataset.value = username.value; }))), dom.label(style({ display: 'block', marginBottom: '2ex' }), dom.div('Password', style({ marginBottom: '.5ex' })), password = dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required(''))), totpLabel = dom.label(style({ display: 'none', marginBottom: '2ex' }), dom.div('Two-factor authentication code, or recovery code', style({ marginBottom: '.5ex' })), totp = dom.input(attr.autocomplete('one-time-code'))), dom.div(style({ textAlign: 'center' }), dom.submitbutton('Login'), window.PublicKeyCredential ? [
+			' ',
+			dom.clickbutton('Login with passkey', attr.title('Login with a passkey registered for your account, without password. Or, after entering your email address and password, use the passkey as second factor. Passkeys are added in the account web interface.'), async function click() {
+				reasonElem.remove();
+				try {
+					fieldset.disabled = true;
+					const loginToken = await client.LoginPrep();
+					const opts = await client.PasskeyLoginStart(loginToken);
+					const assertion = await passkeyGet(opts);
+					const token = await client.PasskeyLogin(loginToken, password.value ? username.value : '', password.value, assertion);
+					loggedIn(token);
+				}
+				catch (err) {
+					console.log('passkey login error', err);
+					window.alert('Error: ' + errmsg(err));
+				}
+				finally {
+					fieldset.disabled = false;
+				}
+			}),
+		] : [], sso = dom.span()))))));
 		document.body.appendChild(root);
 		username.focus();
+		// Offer login through the identity provider, if configured.
+		client.OIDCEnabled().then(enabled => {
+			if (enabled) {
+				dom._kids(sso, ' ', dom.clickbutton('Login with single sign-on', attr.title('Login through the identity provider of your organization.'), function click() {
+					window.location.href = 'oidc/login';
+				}));
+			}
+		}, err => console.log('checking single sign-on', err));
 	});
 };
+// The WebAuthn browser API uses ArrayBuffers, the API base64 strings.
+const base64ToBuffer = (s) => Uint8Array.from(window.atob(s || ''), c => c.charCodeAt(0)).buffer;
+const bufferToBase64 = (buf) => window.btoa(String.fromCharCode(...new Uint8Array(buf)));
+// passkeyGet lets the user select a passkey in the browser, returning the signed
+// response for the login.
+const passkeyGet = async (opts) => {
+	const cred = await window.navigator.credentials.get({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rpId: opts.RPID,
+			timeout: opts.Timeout,
+			userVerification: 'preferred',
+		},
+	});
+	if (!cred) {
+		throw new Error('no passkey selected');
+	}
+	const resp = cred.response;
+	return {
+		CredentialID: bufferToBase64(cred.rawId),
+		ClientDataJSON: bufferToBase64(resp.clientDataJSON),
+		AuthenticatorData: bufferToBase64(resp.authenticatorData),
+		Signature: bufferToBase64(resp.signature),
+	};
+};
 const localStorageGet = (k) => {
 	try {
 		return window.localStorage.getItem(k);
@@ -1770,6 +2443,25 @@ const envelopeIdentity = (l) => {
 	}
 	return null;
 };
+// Returns the From header value for composing as identity.
+const identityFrom = (ident) => (ident.DisplayName ? displayName(ident.DisplayName) + ' ' : '') + '<' + ident.Address + '>';
+// Returns the signature of the identity, or the account signature without identity.
+const identitySignature = (ident) => ident ? (ident.Signature || []).join('\n') : (accountSettings?.Signature || '');
+// Returns the first identity with an address in l, e.g. the recipients of a
+// message being replied to. Without l, the default identity is returned.
+const findIdentity = (l) => {
+	if (!l) {
+		return accountIdentities.find(ident => ident.Default);
+	}
+	for (const a of l) {
+		const emails = [formatEmail(a), a.User + '@' + a.Domain.ASCII].map(s => s.toLowerCase());
+		const ident = accountIdentities.find(ident => emails.includes(ident.Address.toLowerCase()));
+		if (ident) {
+			return ident;
+		}
+	}
+	return undefined;
+};
 // We can display keyboard shortcuts when a user clicks a button that has a shortcut.
 let shortcutElem = dom.div(css('shortcutFlash', { fontSize: '2em', position: 'absolute', left: '.25em', bottom: '.25em', backgroundColor: '#888', padding: '0.25em .5em', color: 'white', borderRadius: '.15em' }));
 let shortcutTimer = 0;
@@ -2193,6 +2885,39 @@ const refineFilters = (f, notf) => {
 	}
 	return [f, notf];
 };
+// Return filters with the words replaced by hashes of whole words, for accounts
+// with a hashed search index, so search words are not sent to the server. The
+// words are split and hashed like the server does when indexing, see
+// api.EventStart.SearchIndexSalt. The original filters are not modified.
+const hashFilters = async (f, notf) => {
+	if (!searchIndexSalt || (f.Words || []).length === 0 && (notf.Words || []).length === 0) {
+		return [f, notf];
+	}
+	const salt = Uint8Array.from(atob(searchIndexSalt), c => c.charCodeAt(0));
+	const key = await window.crypto.subtle.importKey('raw', salt, { name: 'HMAC', hash: 'SHA-256' }, false, ['sign']);
+	const hashes = async (words) => {
+		const l = [];
+		for (const w of words || []) {
+			for (const m of w.toLowerCase().matchAll(/[\p{L}\p{Nd}]+/gu)) {
+				// Long words are indexed as parts of 64 characters, every 32 characters.
+				const chars = Array.from(m[0]);
+				for (let i = 0;; i += 32) {
+					const end = Math.min(i + 64, chars.length);
+					const sig = await window.crypto.subtle.sign('HMAC', key, new TextEncoder().encode(chars.slice(i, end).join('')));
+					l.push(Array.from(new Uint8Array(sig).slice(0, 16)).map(b => b.toString(16).padStart(2, '0')).join(''));
+					if (end === chars.length) {
+						break;
+					}
+				}
+			}
+		}
+		return l.length > 0 ? l : null;
+	};
+	return [
+		{ ...f, Words: null, WordHashes: await hashes(f.Words) },
+		{ ...notf, Words: null, WordHashes: await hashes(notf.Words) },
+	];
+};
 // For dragging the splitter bars. This function should be called on mousedown. e
 // is the mousedown event. Move is the function to call when the bar was dragged,
 // typically adjusting styling, e.g. absolutely positioned offsets, possibly based
@@ -2332,6 +3057,23 @@ const withStatus = async (action, promise, disablable, noAlert) => {
 		}
 	}
 };
+// Show a notice with a button to undo sending a message while its delivery is
+// held back.
+const showUndoSend = (result) => {
+	const remove = () => {
+		window.clearTimeout(id);
+		elem.remove();
+	};
+	const id = window.setTimeout(remove, result.UndoUntil.getTime() - new Date().getTime());
+	const elem = dom.span('Message will be sent shortly. ', dom.clickbutton('Undo', attr.title('Cancel delivery of the message, and move it to the Drafts mailbox for editing.'), async function click() {
+		remove();
+		await withStatus('Undoing send', client.MessageSubmitUndo(result.QueueMsgIDs || [], result.SentMessageID));
+		const done = dom.span('Sending undone, message moved to Drafts. ');
+		statusElem.appendChild(done);
+		window.setTimeout(() => done.remove(), 5000);
+	}), ' ');
+	statusElem.appendChild(elem);
+};
 const withDisabled = async (elem, p) => {
 	try {
 		elem.disabled = true;
@@ -2450,6 +3192,39 @@ const popup = (...kids) => {
 	content.focus();
 	return close;
 };
+// Subscribe this browser to Web Push notifications about new messages, shown by
+// the service worker, and register the subscription with the server.
+const webPushSubscribe = async () => {
+	if (!('serviceWorker' in window.navigator) || !('PushManager' in window)) {
+		throw new Error('Notifications are not supported by your browser, or only on HTTPS URLs.');
+	}
+	if (await Notification.requestPermission() !== 'granted') {
+		throw new Error('Permission for showing notifications was not granted.');
+	}
+	const reg = await window.navigator.serviceWorker.register('sw.js');
+	await window.navigator.serviceWorker.ready;
+	const key = await client.WebPushKey();
+	const keyBytes = Uint8Array.from(atob(key.replace(/-/g, '+').replace(/_/g, '/')), c => c.charCodeAt(0));
+	let sub = await reg.pushManager.getSubscription();
+	if (sub) {
+		// May have been subscribed with the key of another account.
+		await client.WebPushUnsubscribe(sub.endpoint);
+		await sub.unsubscribe();
+	}
+	sub = await reg.pushManager.subscribe({ userVisibleOnly: true, applicationServerKey: keyBytes });
+	const j = sub.toJSON();
+	await client.WebPushSubscribe(sub.endpoint, j.keys?.p256dh || '', j.keys?.auth || '');
+};
+// Unsubscribe this browser from Web Push notifications.
+const webPushUnsubscribe = async () => {
+	const reg = await window.navigator.serviceWorker?.getRegistration('sw.js');
+	const sub = await reg?.pushManager.getSubscription();
+	if (!sub) {
+		return;
+	}
+	await client.WebPushUnsubscribe(sub.endpoint);
+	await sub.unsubscribe();
+};
 // Show settings screen.
 const cmdSettings = async () => {
 	let fieldset;
@@ -2457,8 +3232,14 @@ const cmdSettings = async () => {
 	let quoting;
 	let showAddressSecurity;
 	let showHTML;
+	let remoteContentProxy;
 	let showShortcuts;
 	let showHeaders;
+	let undoSend;
+	let htmlAllowedTags;
+	let htmlAllowedAttributes;
+	let notifyMailboxes;
+	let notifySenders;
 	if (!accountSettings) {
 		throw new Error('No account settings fetched yet.');
 	}
@@ -2471,13 +3252,26 @@ const cmdSettings = async () => {
 			Quoting: quoting.value,
 			ShowAddressSecurity: showAddressSecurity.checked,
 			ShowHTML: showHTML.checked,
+			RemoteContentProxy: remoteContentProxy.checked,
 			NoShowShortcuts: !showShortcuts.checked,
 			ShowHeaders: showHeaders.value.split('\n').map(s => s.trim()).filter(s => !!s),
+			UndoSendSeconds: parseInt(undoSend.value),
+			HTMLAllowedTags: htmlAllowedTags.value.split(/[\s,]+/).filter(s => !!s),
+			HTMLAllowedAttributes: htmlAllowedAttributes.value.split(/[\s,]+/).filter(s => !!s),
+			Labels: accountSettings.Labels, // Not changed by saving settings.
+			NotifyMailboxes: notifyMailboxes.value.split('\n').map(s => s.trim()).filter(s => !!s),
+			NotifySenders: notifySenders.value.split(/[\s,]+/).filter(s => !!s),
 		};
 		await withDisabled(fieldset, client.SettingsSave(accSet));
 		accountSettings = accSet;
 		remove();
-	}, fieldset = dom.fieldset(dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Signature'), signature = dom.textarea(new String(accountSettings.Signature), style({ width: '100%' }), attr.rows('' + Math.max(3, 1 + accountSettings.Signature.split('\n').length)))), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Reply above/below original'), attr.title('Auto: If text is selected, only the replied text is quoted and editing starts below. Otherwise, the full message is quoted and editing starts at the top.'), quoting = dom.select(dom.option(attr.value(''), 'Auto'), dom.option(attr.value('bottom'), 'Bottom', accountSettings.Quoting === api.Quoting.Bottom ? attr.selected('') : []), dom.option(attr.value('top'), 'Top', accountSettings.Quoting === api.Quoting.Top ? attr.selected('') : []))), dom.label(style({ margin: '1ex 0', display: 'block' }), showAddressSecurity = dom.input(attr.type('checkbox'), accountSettings.ShowAddressSecurity ? attr.checked('') : []), ' Show address security indications', attr.title('Show bars underneath address input fields, indicating support for STARTTLS/DNSSEC/DANE/MTA-STS/RequireTLS.')), dom.label(style({ margin: '1ex 0', display: 'block' }), showHTML = dom.input(attr.type('checkbox'), accountSettings.ShowHTML ? attr.checked('') : []), ' Show email as HTML instead of text by default for first-time senders', attr.title('Whether to show HTML or text is remembered per sender. This sets the default for unknown correspondents.')), dom.label(style({ margin: '1ex 0', display: 'block' }), showShortcuts = dom.input(attr.type('checkbox'), accountSettings.NoShowShortcuts ? [] : attr.checked('')), ' Show shortcut keys in bottom left after interaction with mouse'), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Show additional headers'), showHeaders = dom.textarea(new String((accountSettings.ShowHeaders || []).join('\n')), style({ width: '100%' }), attr.rows('' + Math.max(3, 1 + (accountSettings.ShowHeaders || []).length))), dom.div(style({ fontStyle: 'italic' }), 'One header name per line, for example Delivered-To, X-Mox-Reason, User-Agent, ...; Refresh mailbox view for changes to take effect.')), dom.div(style({ marginTop: '2ex' }), 'Register "mailto:" links with the browser/operating system to compose a message in webmail.', dom.br(), dom.clickbutton('Register', attr.title('In most browsers, registering is only allowed on HTTPS URLs. Your browser may ask for confirmation. If nothing appears to happen, the registration may already have been present.'), function click() {
+	}, fieldset = dom.fieldset(dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Signature'), signature = dom.textarea(new String(accountSettings.Signature), style({ width: '100%' }), attr.rows('' + Math.max(3, 1 + accountSettings.Signature.split('\n').length)))), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Reply above/below original'), attr.title('Auto: If text is selected, only the replied text is quoted and editing starts below. Otherwise, the full message is quoted and editing starts at the top.'), quoting = dom.select(dom.option(attr.value(''), 'Auto'), dom.option(attr.value('bottom'), 'Bottom', accountSettings.Quoting === api.Quoting.Bottom ? attr.selected('') : []), dom.option(attr.value('top'), 'Top', accountSettings.Quoting === api.Quoting.Top ? attr.selected('') : []))), dom.label(style({ margin: '1ex 0', display: 'block' }), showAddressSecurity = dom.input(attr.type('checkbox'), accountSettings.ShowAddressSecurity ? attr.checked('') : []), ' Show address security indications', attr.title('Show bars underneath address input fields, indicating support for STARTTLS/DNSSEC/DANE/MTA-STS/RequireTLS.')), dom.label(style({ margin: '1ex 0', display: 'block' }), showHTML = dom.input(attr.type('checkbox'), accountSettings.ShowHTML ? attr.checked('') : []), ' Show email as HTML instead of text by default for first-time senders', attr.title('Whether to show HTML or text is remembered per sender. This sets the default for unknown correspondents.')), dom.label(style({ margin: '1ex 0', display: 'block' }), remoteContentProxy = dom.input(attr.type('checkbox'), accountSettings.RemoteContentProxy ? attr.checked('') : []), ' Load remote content in HTML messages through proxy', attr.title('When showing HTML with external resources, images are fetched by the mail server, so senders do not see your IP address and browser. Tracking parameters are removed from the URLs.')), dom.label(style({ margin: '1ex 0', display: 'block' }), showShortcuts = dom.input(attr.type('checkbox'), accountSettings.NoShowShortcuts ? [] : attr.checked('')), ' Show shortcut keys in bottom left after interaction with mouse'), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Undo send'), attr.title('Hold back delivery of sent messages for a few seconds, during which sending can be undone.'), undoSend = dom.select([0, 5, 10, 20, 30].map(n => dom.option(attr.value('' + n), n ? n + ' seconds' : 'Disabled', accountSettings.UndoSendSeconds === n ? attr.selected('') : [])))), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Show additional headers'), showHeaders = dom.textarea(new String((accountSettings.ShowHeaders || []).join('\n')), style({ width: '100%' }), attr.rows('' + Math.max(3, 1 + (accountSettings.ShowHeaders || []).length))), dom.div(style({ fontStyle: 'italic' }), 'One header name per line, for example Delivered-To, X-Mox-Reason, User-Agent, ...; Refresh mailbox view for changes to take effect.')), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Allowed HTML elements'), attr.title('HTML elements kept in messages sent with HTML and in HTML messages shown, others are removed. If empty, a default set of formatting elements is used for sent messages, and shown messages only have scripts removed.'), htmlAllowedTags = dom.input(attr.value((accountSettings.HTMLAllowedTags || []).join(' ')), style({ width: '100%' }), attr.placeholder('p a img table ...'))), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Allowed HTML attributes'), attr.title('HTML attributes kept in messages sent with HTML and in HTML messages shown. Event handlers and links with unsafe schemes like "javascript:" are always removed.'), htmlAllowedAttributes = dom.input(attr.value((accountSettings.HTMLAllowedAttributes || []).join(' ')), style({ width: '100%' }), attr.placeholder('href src alt style ...'))), dom.div(style({ marginTop: '2ex' }), 'Notifications about new messages in this browser, also when the webmail is not open.', dom.br(), dom.clickbutton('Enable', attr.title('Your browser will ask for permission to show notifications. Notifications are sent through the push service of your browser vendor, typically only on HTTPS URLs.'), async function click(e) {
+		await withStatus('Enabling notifications', webPushSubscribe(), e.target);
+		window.alert('Notifications enabled for this browser.');
+	}), ' ', dom.clickbutton('Disable', async function click(e) {
+		await withStatus('Disabling notifications', webPushUnsubscribe(), e.target);
+		window.alert('Notifications disabled for this browser.');
+	})), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Notify about new messages in mailboxes'), attr.title('If empty, only new messages in the Inbox cause a notification.'), notifyMailboxes = dom.textarea(new String((accountSettings.NotifyMailboxes || []).join('\n')), style({ width: '100%' }), attr.rows('' + Math.max(2, 1 + (accountSettings.NotifyMailboxes || []).length)), attr.placeholder('Inbox')), dom.div(style({ fontStyle: 'italic' }), 'One mailbox name per line.')), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Notify about new messages from senders'), attr.title('Email addresses, or domains starting with "@". If empty, new messages from all senders cause a notification.'), notifySenders = dom.input(attr.value((accountSettings.NotifySenders || []).join(' ')), style({ width: '100%' }), attr.placeholder('user@example.org @example.com ...'))), dom.div(style({ marginTop: '2ex' }), 'Register "mailto:" links with the browser/operating system to compose a message in webmail.', dom.br(), dom.clickbutton('Register', attr.title('In most browsers, registering is only allowed on HTTPS URLs. Your browser may ask for confirmation. If nothing appears to happen, the registration may already have been present.'), function click() {
 		if (!window.navigator.registerProtocolHandler) {
 			window.alert('Registering a protocol handler ("mailto:") is not supported by your browser.');
 			return;
@@ -2495,15 +3289,433 @@ const cmdSettings = async () => {
 			window.alert('Unregistering a protocol handler ("mailto:") via JavaScript is not supported by your browser. See your browser settings to unregister.');
 			return;
 		}
-		try {
-			window.navigator.unregisterProtocolHandler('mailto', '#compose %s');
+		try {
+			window.navigator.unregisterProtocolHandler('mailto', '#compose %s');
+		}
+		catch (err) {
+			window.alert('Error unregistering "mailto:" protocol handler: ' + errmsg(err));
+			return;
+		}
+		window.alert('"mailto:" protocol handler unregistered.');
+	})), dom.div(style({ marginTop: '2ex' }), 'Templates for composing messages, e.g. canned responses.', dom.br(), dom.clickbutton('Manage templates', async function click() {
+		remove();
+		await cmdTemplates();
+	})), dom.div(style({ marginTop: '2ex' }), 'Labels on messages, with colors and their order.', dom.br(), dom.clickbutton('Manage labels', async function click() {
+		remove();
+		cmdLabels();
+	})), dom.div(style({ marginTop: '2ex' }), 'Rules for incoming messages, e.g. moving messages from a mailing list to a mailbox.', dom.br(), dom.clickbutton('Manage rules', async function click() {
+		remove();
+		await cmdRules();
+	})), dom.div(style({ marginTop: '2ex' }), 'Access to mailboxes given to other accounts, e.g. for sharing a support mailbox.', dom.br(), dom.clickbutton('Manage delegation', async function click() {
+		remove();
+		await cmdDelegations();
+	})), dom.br(), dom.div(dom.submitbutton('Save')))));
+};
+// readFiles reads files, e.g. from a file input, as data URIs.
+const readFiles = (files) => {
+	return new Promise((resolve, reject) => {
+		const l = [];
+		if (!files || files.length === 0) {
+			resolve(l);
+			return;
+		}
+		[...files].forEach(f => {
+			const fr = new window.FileReader();
+			fr.addEventListener('load', () => {
+				l.push({ Filename: f.name, DataURI: fr.result });
+				if (l.length === files.length) {
+					resolve(l);
+				}
+			});
+			fr.addEventListener('error', () => {
+				reject(fr.error);
+			});
+			fr.readAsDataURL(f);
+		});
+	});
+};
+// Show popup to manage message templates of the account.
+const cmdTemplates = async () => {
+	const [templates, shared] = await withStatus('Fetching templates', client.Templates());
+	let l = templates || [];
+	let listElem;
+	let editElem;
+	const renderList = () => {
+		dom._kids(listElem, l.length === 0 ? dom.div(style({ fontStyle: 'italic' }), 'No templates yet.') : dom.table(l.map(t => dom.tr(dom.td(t.Name), dom.td(styleClasses.textMild, t.Subject), dom.td(dom.clickbutton('Edit', function click() { edit(t); }), ' ', dom.clickbutton('Remove', async function click(e) {
+			if (!window.confirm('Are you sure you want to remove template "' + t.Name + '"?')) {
+				return;
+			}
+			await withStatus('Removing template', client.TemplateRemove(t.ID), e.target);
+			l = l.filter(x => x !== t);
+			renderList();
+			dom._kids(editElem);
+		}))))));
+	};
+	const edit = (t) => {
+		let fieldset;
+		let name;
+		let subject;
+		let text;
+		let files;
+		let attachmentsElem;
+		let attachments = t.Attachments || [];
+		const renderAttachments = () => {
+			dom._kids(attachmentsElem, attachments.map(a => [
+				dom.span(a.Filename || '(unnamed)', ' ', dom.clickbutton('Remove', function click() {
+					attachments = attachments.filter(x => x !== a);
+					renderAttachments();
+				})),
+				' ',
+			]));
+		};
+		dom._kids(editElem, dom.h2(t.ID ? 'Edit template' : 'New template'), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			const nfiles = await readFiles(files.files);
+			const nt = {
+				ID: t.ID,
+				Name: name.value,
+				Subject: subject.value,
+				Text: text.value,
+				Attachments: [...attachments, ...nfiles],
+			};
+			const st = await withStatus('Saving template', client.TemplateSave(nt), fieldset);
+			l = [...l.filter(x => x.ID !== st.ID), st];
+			l.sort((a, b) => a.Name < b.Name ? -1 : (a.Name > b.Name ? 1 : 0));
+			renderList();
+			dom._kids(editElem);
+		}, fieldset = dom.fieldset(dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Name'), name = dom.input(attr.value(t.Name), attr.required(''))), dom.label(style({ margin: '1ex 0', display: 'block' }), attr.title('If set, replaces the subject when the template is used.'), dom.div('Subject (optional)'), subject = dom.input(attr.value(t.Subject), style({ width: '100%' }))), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Text'), text = dom.textarea(new String(t.Text), style({ width: '100%' }), attr.rows('8')), dom.div(style({ fontStyle: 'italic' }), 'Placeholders: {{recipient.name}}, {{recipient.firstname}}, {{recipient.email}}, {{sender.name}}, {{sender.email}}, {{date}}.')), dom.div(style({ margin: '1ex 0' }), 'Attachments ', attachmentsElem = dom.span(), files = dom.input(attr.type('file'), attr.multiple(''))), dom.div(dom.submitbutton('Save'), ' ', dom.clickbutton('Cancel', function click() { dom._kids(editElem); })))));
+		renderAttachments();
+		name.focus();
+	};
+	popup(css('popupTemplates', { minWidth: '30em' }), style({ maxWidth: '50em' }), dom.h1('Templates'), listElem = dom.div(), dom.div(style({ margin: '1ex 0' }), dom.clickbutton('New template', function click() {
+		edit({ ID: 0, Name: '', Subject: '', Text: '', Attachments: [] });
+	})), editElem = dom.div(), (shared || []).length === 0 ? [] : [
+		dom.h2('Shared templates'),
+		dom.div(style({ fontStyle: 'italic' }), 'Configured by the administrator for domains of your addresses.'),
+		dom.ul((shared || []).map(t => dom.li(t.Name, ' ', dom.span(styleClasses.textMild, '(' + t.Domain + ')')))),
+	]);
+	renderList();
+};
+// Show popup explaining the junk analysis done during delivery of a message: the
+// signals, and the words that contributed to the score of the junk filter.
+const showJunkVerdict = async (msgID, target) => {
+	const v = await withStatus('Fetching junk verdict', client.MessageJunkVerdict(msgID), target);
+	if (!v) {
+		window.alert('No junk verdict was stored for this message.');
+		return;
+	}
+	const words = (l) => (l || []).length === 0 ?
+		dom.div(styleClasses.textMild, 'None.') :
+		dom.ul((l || []).map(w => dom.li(w.Word + ' ', dom.span(styleClasses.textMild, '(' + w.Score.toFixed(3) + ')'))));
+	popup(style({ maxWidth: '50em' }), dom.h1('Junk verdict'), dom.p((v.Accept ? 'Accepted' : 'Rejected') + (v.Mailbox ? ' to mailbox ' + v.Mailbox : '') + ', reason: ' + v.Reason), v.Classified ? [
+		dom.h2('Junk filter'),
+		dom.p('Spam score ' + v.Score.toFixed(2) + ', threshold ' + v.Threshold.toFixed(2) + (v.Significant ? '' : ', not significant due to too few known words') + '.'),
+		dom.h2('Spam words'),
+		words(v.SpamWords),
+		dom.h2('Ham words'),
+		words(v.HamWords),
+	] : dom.p('The junk filter was not used, the decision was based on the reputation of the sender.'), (v.Details || []).length > 0 ? [
+		dom.h2('Signals'),
+		dom.ul((v.Details || []).map(s => dom.li(s))),
+	] : []);
+};
+// Show popup to manage labels: their colors, names and order. Renaming and
+// removing a label also changes the messages with the label, and the labels below
+// it in the hierarchy.
+const cmdLabels = () => {
+	let listElem;
+	let fieldset;
+	let name;
+	let color;
+	const labels = () => accountSettings.Labels || [];
+	const below = (name, label) => name === label || name.startsWith(label + '/');
+	const setLabels = (l) => {
+		accountSettings = { ...accountSettings, Labels: l };
+		renderList();
+	};
+	const save = async (e, label) => {
+		const nl = await withStatus('Saving label', client.LabelSave(label), e.target);
+		setLabels(labels().map(x => x.Name === nl.Name ? nl : x));
+	};
+	const move = async (e, i, delta) => {
+		const l = [...labels()];
+		const [x] = l.splice(i, 1);
+		l.splice(i + delta, 0, x);
+		await withStatus('Saving label order', client.LabelsOrder(l.map(x => x.Name)), e.target);
+		setLabels(l);
+	};
+	const renderList = () => {
+		const l = labels();
+		dom._kids(listElem, l.length === 0 ? dom.div(style({ fontStyle: 'italic' }), 'No labels yet.') : dom.table(l.map((x, i) => dom.tr(dom.td(style({ paddingLeft: (x.Name.split('/').length - 1) + 'em' }), dom.span(styleClasses.keyword, keywordColor(x.Name), x.Name)), dom.td(dom.input(attr.type('color'), attr.value(x.Color || '#ffffff'), attr.title('Color of the label.'), async function change(e) {
+			await save(e, { ...x, Color: e.target.value });
+		}), ' ', x.Color ? dom.clickbutton('No color', async function click(e) {
+			await save(e, { ...x, Color: '' });
+		}) : []), dom.td(dom.clickbutton('↑', attr.title('Move up.'), i === 0 ? attr.disabled('') : [], async function click(e) {
+			await move(e, i, -1);
+		}), ' ', dom.clickbutton('↓', attr.title('Move down.'), i === l.length - 1 ? attr.disabled('') : [], async function click(e) {
+			await move(e, i, 1);
+		}), ' ', dom.clickbutton('Rename', async function click(e) {
+			let nname = window.prompt('New name for label "' + x.Name + '", also changed on messages. Use a slash for hierarchy, e.g. "work/project".', x.Name);
+			nname = (nname || '').trim().toLowerCase();
+			if (!nname || nname === x.Name) {
+				return;
+			}
+			await withStatus('Renaming label', client.LabelRename(x.Name, nname), e.target);
+			const nl = [];
+			for (const y of labels()) {
+				const n = below(y.Name, x.Name) ? nname + y.Name.substring(x.Name.length) : y.Name;
+				if (!nl.find(z => z.Name === n)) {
+					nl.push({ ...y, Name: n });
+				}
+			}
+			setLabels(nl);
+		}), ' ', dom.clickbutton('Remove', async function click(e) {
+			if (!window.confirm('Are you sure you want to remove label "' + x.Name + '" and the labels below it? They are also removed from all messages.')) {
+				return;
+			}
+			await withStatus('Removing label', client.LabelRemove(x.Name), e.target);
+			setLabels(labels().filter(y => !below(y.Name, x.Name)));
+		}))))));
+	};
+	popup(css('popupLabels', { minWidth: '30em' }), style({ maxWidth: '50em' }), dom.h1('Labels'), listElem = dom.div(), dom.h2('New label'), dom.form(async function submit(e) {
+		e.preventDefault();
+		e.stopPropagation();
+		const nl = await withStatus('Adding label', client.LabelSave({ Name: name.value, Color: color.value }), fieldset);
+		setLabels(labels().find(x => x.Name === nl.Name) ? labels().map(x => x.Name === nl.Name ? nl : x) : [...labels(), nl]);
+		name.value = '';
+	}, fieldset = dom.fieldset(name = dom.input(attr.required(''), attr.placeholder('work/project'), attr.title('Name of the label, a slash separates levels in the hierarchy.')), ' ', color = dom.input(attr.type('color'), attr.value('#ffffff')), ' ', dom.submitbutton('Add'))));
+	renderList();
+};
+// Show popup to manage rules for incoming messages.
+const cmdRules = async () => {
+	let l = (await withStatus('Fetching rules', client.Rules())) || [];
+	let listElem;
+	let editElem;
+	const describe = (r) => {
+		const conds = [];
+		if (r.From) {
+			conds.push('from contains "' + r.From + '"');
+		}
+		if (r.To) {
+			conds.push('to/cc contains "' + r.To + '"');
+		}
+		if (r.SubjectContains) {
+			conds.push('subject contains "' + r.SubjectContains + '"');
+		}
+		if (r.HasAttachment) {
+			conds.push('has attachment');
+		}
+		if (r.ListID) {
+			conds.push('list-id contains "' + r.ListID + '"');
+		}
+		const actions = [];
+		if (r.Mailbox) {
+			actions.push('move to ' + r.Mailbox);
+		}
+		if (r.Delete) {
+			actions.push('delete');
+		}
+		if (r.MarkRead) {
+			actions.push('mark read');
+		}
+		if ((r.ForwardTo || []).length > 0) {
+			actions.push('forward to ' + (r.ForwardTo || []).join(', '));
+		}
+		if (r.Stop) {
+			actions.push('stop');
+		}
+		return (conds.length === 0 ? 'all messages' : conds.join(' and ')) + ': ' + actions.join(', ');
+	};
+	const renderList = () => {
+		dom._kids(listElem, l.length === 0 ? dom.div(style({ fontStyle: 'italic' }), 'No rules yet.') : dom.table(l.map(r => dom.tr(dom.td(r.Name, r.Disabled ? dom.span(styleClasses.textMild, ' (disabled)') : []), dom.td(styleClasses.textMild, describe(r)), dom.td(dom.clickbutton('Edit', function click() { edit(r); }), ' ', dom.clickbutton('Remove', async function click(e) {
+			if (!window.confirm('Are you sure you want to remove rule "' + r.Name + '"?')) {
+				return;
+			}
+			await withStatus('Removing rule', client.RuleRemove(r.ID), e.target);
+			l = l.filter(x => x !== r);
+			renderList();
+			dom._kids(editElem);
+		}))))));
+	};
+	const edit = (r) => {
+		let fieldset;
+		let name;
+		let position;
+		let disabled;
+		let from;
+		let to;
+		let subject;
+		let hasAttachment;
+		let listID;
+		let mailbox;
+		let markRead;
+		let forwardTo;
+		let del;
+		let stop;
+		const field = (label, e, title) => dom.label(style({ margin: '1ex 0', display: 'block' }), title ? attr.title(title) : [], dom.div(label), e);
+		const check = (e, label) => dom.label(style({ margin: '1ex 0', display: 'block' }), e, ' ', label);
+		dom._kids(editElem, dom.h2(r.ID ? 'Edit rule' : 'New rule'), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			const nr = {
+				ID: r.ID,
+				Position: parseInt(position.value) || 0,
+				Name: name.value,
+				Disabled: disabled.checked,
+				From: from.value.trim(),
+				To: to.value.trim(),
+				SubjectContains: subject.value.trim(),
+				HasAttachment: hasAttachment.checked,
+				ListID: listID.value.trim(),
+				Mailbox: mailbox.value.trim(),
+				MarkRead: markRead.checked,
+				ForwardTo: forwardTo.value.split(/[\s,]+/).filter(s => !!s),
+				Delete: del.checked,
+				Stop: stop.checked,
+			};
+			const sr = await withStatus('Saving rule', client.RuleSave(nr), fieldset);
+			l = [...l.filter(x => x.ID !== sr.ID), sr];
+			l.sort((a, b) => a.Position !== b.Position ? a.Position - b.Position : a.ID - b.ID);
+			renderList();
+			dom._kids(editElem);
+		}, fieldset = dom.fieldset(field('Name', name = dom.input(attr.value(r.Name), attr.required(''))), field('Position', position = dom.input(attr.type('number'), attr.value('' + r.Position)), 'Rules are evaluated in ascending position.'), check(disabled = dom.input(attr.type('checkbox'), r.Disabled ? attr.checked('') : []), 'Disabled'), dom.h3('Conditions'), dom.div(style({ fontStyle: 'italic' }), 'All conditions that are set must match. Text is matched case-insensitively anywhere in the field.'), field('From', from = dom.input(attr.value(r.From), style({ width: '100%' })), 'Address or name in From header.'), field('To or Cc', to = dom.input(attr.value(r.To), style({ width: '100%' })), 'Address or name in To or Cc header.'), field('Subject contains', subject = dom.input(attr.value(r.SubjectContains), style({ width: '100%' }))), field('List-Id', listID = dom.input(attr.value(r.ListID), style({ width: '100%' })), 'Identifier of mailing list, in List-Id header.'), check(hasAttachment = dom.input(attr.type('checkbox'), r.HasAttachment ? attr.checked('') : []), 'Has attachment'), dom.h3('Actions'), field('Move to mailbox', mailbox = dom.input(attr.value(r.Mailbox), style({ width: '100%' }))), check(del = dom.input(attr.type('checkbox'), r.Delete ? attr.checked('') : []), 'Delete, moving to trash'), check(markRead = dom.input(attr.type('checkbox'), r.MarkRead ? attr.checked('') : []), 'Mark as read'), field('Forward to', forwardTo = dom.input(attr.value((r.ForwardTo || []).join(', ')), style({ width: '100%' })), 'Addresses to forward incoming messages to, as attachment. Not applied when running rules on existing messages.'), check(stop = dom.input(attr.type('checkbox'), r.Stop ? attr.checked('') : []), 'Stop evaluating later rules'), dom.div(dom.submitbutton('Save'), ' ', dom.clickbutton('Cancel', function click() { dom._kids(editElem); })))));
+		name.focus();
+	};
+	popup(css('popupRules', { minWidth: '30em' }), style({ maxWidth: '50em' }), dom.h1('Rules'), dom.div(style({ fontStyle: 'italic' }), 'Rules are applied to incoming messages after delivery. Use "Run rules" in the actions of a mailbox to apply them to existing messages.'), listElem = dom.div(style({ margin: '1ex 0' })), dom.div(style({ margin: '1ex 0' }), dom.clickbutton('New rule', function click() {
+		const position = l.length === 0 ? 0 : l[l.length - 1].Position + 1;
+		edit({ ID: 0, Position: position, Name: '', Disabled: false, From: '', To: '', SubjectContains: '', HasAttachment: false, ListID: '', Mailbox: '', MarkRead: false, ForwardTo: [], Delete: false, Stop: false });
+	})), editElem = dom.div());
+	renderList();
+};
+// Manage access to mailboxes given to other accounts, and open mailboxes of other
+// accounts that gave access.
+const cmdDelegations = async () => {
+	const [given0, received0] = await withStatus('Fetching delegations', client.Delegations());
+	let given = given0 || [];
+	const received = received0 || [];
+	let givenElem;
+	let editElem;
+	const sendText = (send) => send === 'as' ? 'send as' : (send === 'onbehalf' ? 'send on behalf' : '');
+	const renderGiven = () => {
+		dom._kids(givenElem, given.length === 0 ? dom.div(style({ fontStyle: 'italic' }), 'No access given to other accounts.') : dom.table(given.map(d => dom.tr(dom.td(d.Account), dom.td(styleClasses.textMild, [(d.Mailboxes || []).join(', '), d.Write ? 'read/write' : 'read-only', sendText(d.Send)].filter(s => !!s).join('; ')), dom.td(dom.clickbutton('Edit', function click() { edit(d); }), ' ', dom.clickbutton('Remove', async function click(e) {
+			if (!window.confirm('Are you sure you want to remove access for account "' + d.Account + '"?')) {
+				return;
+			}
+			await withStatus('Removing delegation', client.DelegationRemove(d.Account), e.target);
+			given = given.filter(x => x !== d);
+			renderGiven();
+			dom._kids(editElem);
+		}))))));
+	};
+	const edit = (d) => {
+		let fieldset;
+		let account;
+		let mailboxes;
+		let write;
+		let send;
+		dom._kids(editElem, dom.h2(d.Account ? 'Edit access' : 'Give access'), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			const nd = {
+				Account: account.value.trim(),
+				Mailboxes: mailboxes.value.split(',').map(s => s.trim()).filter(s => !!s),
+				Write: write.checked,
+				Send: send.value,
+			};
+			await withStatus('Saving delegation', client.DelegationSave(nd), fieldset);
+			given = [...given.filter(x => x.Account !== nd.Account), nd];
+			renderGiven();
+			dom._kids(editElem);
+		}, fieldset = dom.fieldset(dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Account'), account = dom.input(attr.value(d.Account), attr.required(''), d.Account ? attr.disabled('') : [])), dom.label(style({ margin: '1ex 0', display: 'block' }), attr.title('Names of mailboxes, separated by commas. Child mailboxes are not included. Access is by name: after renaming a mailbox, update the name here.'), dom.div('Mailboxes'), mailboxes = dom.input(attr.value((d.Mailboxes || []).join(', ')), attr.required(''), style({ width: '100%' }))), dom.label(style({ margin: '1ex 0', display: 'block' }), write = dom.input(attr.type('checkbox'), d.Write ? attr.checked('') : []), ' Allow marking messages as (un)read, moving between the mailboxes and deleting'), dom.label(style({ margin: '1ex 0', display: 'block' }), dom.div('Sending messages with addresses of this account'), send = dom.select(dom.option('Not allowed', attr.value(''), d.Send === '' ? attr.selected('') : []), dom.option('Send as this account', attr.value('as'), d.Send === 'as' ? attr.selected('') : []), dom.option('Send on behalf of this account, with Sender header', attr.value('onbehalf'), d.Send === 'onbehalf' ? attr.selected('') : []))), dom.div(dom.submitbutton('Save'), ' ', dom.clickbutton('Cancel', function click() { dom._kids(editElem); })))));
+		if (d.Account) {
+			mailboxes.focus();
+		}
+		else {
+			account.focus();
 		}
-		catch (err) {
-			window.alert('Error unregistering "mailto:" protocol handler: ' + errmsg(err));
+	};
+	const remove = popup(css('popupDelegations', { minWidth: '30em' }), style({ maxWidth: '50em' }), dom.h1('Delegation'), dom.h2('Access given to other accounts'), givenElem = dom.div(style({ margin: '1ex 0' })), dom.div(style({ margin: '1ex 0' }), dom.clickbutton('Give access', function click() {
+		edit({ Account: '', Mailboxes: ['Inbox'], Write: false, Send: '' });
+	})), editElem = dom.div(), dom.h2('Access given by other accounts'), received.length === 0 ? dom.div(style({ fontStyle: 'italic' }), 'No access given by other accounts.') : dom.table(received.map(da => dom.tr(dom.td(da.Account), dom.td((da.Mailboxes || []).map(name => [
+		dom.clickbutton(name, attr.title('Open mailbox of account ' + da.Account + '.'), function click() {
+			remove();
+			cmdDelegatedMailbox(da, name);
+		}),
+		' ',
+	])), dom.td(styleClasses.textMild, [da.Write ? 'read/write' : 'read-only', sendText(da.Send)].filter(s => !!s).join('; '))))));
+	renderGiven();
+};
+// Show messages in a mailbox of another account that gave access.
+const cmdDelegatedMailbox = async (da, mailbox) => {
+	const pageSize = 100;
+	let items = [];
+	let selected = null;
+	let mailboxElem;
+	let listElem;
+	let msgElem;
+	const load = async (offset) => {
+		const l = (await withStatus('Fetching messages', client.DelegatedMessages(da.Account, mailbox, offset, pageSize))) || [];
+		items = offset === 0 ? l : [...items, ...l];
+		renderList(l.length === pageSize);
+	};
+	const renderList = (more) => {
+		dom._kids(listElem, items.length === 0 ? dom.div(style({ fontStyle: 'italic' }), 'No messages.') : dom.table(style({ width: '100%' }), items.map(mi => dom.tr(mi === selected ? style({ backgroundColor: styles.highlightBackground }) : [], mi.Message.Seen ? [] : style({ fontWeight: 'bold' }), style({ cursor: 'pointer' }), function click() {
+			show(mi);
+		}, dom.td((mi.Envelope.From || []).map(a => formatAddress(a)).join(', ')), dom.td(mi.Envelope.Subject || '(no subject)'), dom.td(styleClasses.textMild, style({ whiteSpace: 'nowrap' }), mi.Message.Received.toLocaleString())))), more ? dom.clickbutton('More...', function click() {
+			load(items.length);
+		}) : []);
+	};
+	const flags = async (mi, seen, target) => {
+		if (seen) {
+			await withStatus('Marking as read', client.DelegatedFlagsAdd(da.Account, [mi.Message.ID], ['\\seen']), target);
+		}
+		else {
+			await withStatus('Marking as unread', client.DelegatedFlagsClear(da.Account, [mi.Message.ID], ['\\seen']), target);
+		}
+		mi.Message.Seen = seen;
+		renderList(items.length % pageSize === 0);
+		show(mi);
+	};
+	const removeItem = (mi) => {
+		items = items.filter(x => x !== mi);
+		selected = null;
+		dom._kids(msgElem);
+		renderList(false);
+	};
+	const show = async (mi) => {
+		selected = mi;
+		renderList(items.length % pageSize === 0);
+		const pm = await withStatus('Fetching message', client.DelegatedParsedMessage(da.Account, mi.Message.ID));
+		if (selected !== mi) {
 			return;
 		}
-		window.alert('"mailto:" protocol handler unregistered.');
-	})), dom.br(), dom.div(dom.submitbutton('Save')))));
+		let moveElem;
+		dom._kids(msgElem, dom.div(style({ margin: '1ex 0' }), dom.div(dom.b('From: '), (mi.Envelope.From || []).map(a => formatAddress(a)).join(', ')), dom.div(dom.b('To: '), (mi.Envelope.To || []).map(a => formatAddress(a)).join(', ')), dom.div(dom.b('Subject: '), mi.Envelope.Subject), dom.div(dom.b('Received: '), mi.Message.Received.toLocaleString())), da.Write ? dom.div(style({ margin: '1ex 0' }), mi.Message.Seen ?
+			dom.clickbutton('Mark unread', async function click(e) { await flags(mi, false, e.target); }) :
+			dom.clickbutton('Mark read', async function click(e) { await flags(mi, true, e.target); }), ' ', (da.Mailboxes || []).length <= 1 ? [] : [
+			moveElem = dom.select((da.Mailboxes || []).filter(name => name !== mailbox).map(name => dom.option(name))),
+			' ',
+			dom.clickbutton('Move', async function click(e) {
+				await withStatus('Moving message', client.DelegatedMessageMove(da.Account, [mi.Message.ID], moveElem.value), e.target);
+				removeItem(mi);
+			}),
+			' ',
+		], dom.clickbutton('Delete', async function click(e) {
+			if (!window.confirm('Are you sure you want to permanently delete this message?')) {
+				return;
+			}
+			await withStatus('Deleting message', client.DelegatedMessageDelete(da.Account, [mi.Message.ID]), e.target);
+			removeItem(mi);
+		})) : [], dom.div(dom._class('mono'), style({ whiteSpace: 'pre-wrap', borderTop: '1px solid', borderTopColor: styles.borderColor, paddingTop: '1ex' }), (pm.Texts || []).join('\n\n')));
+	};
+	popup(css('popupDelegatedMailbox', { minWidth: '40em' }), style({ maxWidth: '60em' }), dom.h1('Mailbox of account ', da.Account), dom.div(style({ margin: '1ex 0' }), mailboxElem = dom.select((da.Mailboxes || []).map(name => dom.option(name, name === mailbox ? attr.selected('') : [])), async function change() {
+		mailbox = mailboxElem.value;
+		selected = null;
+		dom._kids(msgElem);
+		await load(0);
+	}), ' ', da.Write ? '' : dom.span(styleClasses.textMild, 'Read-only access.')), listElem = dom.div(style({ maxHeight: '20em', overflowY: 'auto' })), msgElem = dom.div());
+	await load(0);
 };
 // Show help popup, with shortcuts and basic explanation.
 const cmdHelp = async () => {
@@ -2514,6 +3726,7 @@ const cmdHelp = async () => {
 		['?', 'help'],
 		['ctrl ?', 'tooltip for focused element'],
 		['ctrl m', 'focus message'],
+		['B', 'apply action to all messages matching mailbox or search'],
 	].map(t => dom.tr(dom.td(t[0]), dom.td(t[1]))), dom.tr(dom.td(attr.colspan('2'), dom.h2('Mailbox', style({ margin: '0' })))), [
 		['←', 'collapse'],
 		['→', 'expand'],
@@ -2631,41 +3844,38 @@ const compose = (opts, listMailboxes) => {
 	let body;
 	let attachments;
 	let requiretls;
+	let pgpElem, pgpSign, pgpEncrypt, pgpPassphrase;
 	let toBtn, ccBtn, bccBtn, replyToBtn, customFromBtn;
 	let replyToCell, toCell, ccCell, bccCell; // Where we append new address views.
 	let toRow, replyToRow, ccRow, bccRow; // We show/hide rows as needed.
 	let toViews = [], replytoViews = [], ccViews = [], bccViews = [];
 	let forwardAttachmentViews = [];
-	// todo future: upload attachments with draft messages. would mean we let users remove them again too.
-	// We automatically save drafts 1m after a change. When closing window, we ask to
-	// save unsaved change to draft.
+	let attachOriginal = null;
+	let templateAttachments = [];
+	let templateAttachmentsElem;
+	let templateElem;
+	let templateSelect;
+	let templateRefs = [];
+	// Compose state is autosaved on the server a few seconds after a change, with
+	// uploaded attachments, so it can be continued after closing the browser or on
+	// another device. The version of the draft detects changes made elsewhere.
+	let draft = opts.draft || null;
+	let draftAttachmentsElem;
+	let attachmentsPromise = Promise.resolve();
+	let autosaveTimer = 0;
+	let autosavePromise = Promise.resolve();
+	// Drafts can also be saved as message to the Drafts mailbox, explicitly or when
+	// closing the window. When closing window, we ask to save unsaved changes to
+	// draft.
 	let draftMessageID = opts.draftMessageID || 0;
-	let draftSaveTimer = 0;
 	let draftSavePromise = Promise.resolve(0);
 	let draftLastText = opts.body;
-	const draftCancelSaveTimer = () => {
-		if (draftSaveTimer) {
-			window.clearTimeout(draftSaveTimer);
-			draftSaveTimer = 0;
-		}
-	};
-	const draftScheduleSave = () => {
-		if (draftSaveTimer || body.value === draftLastText) {
-			return;
-		}
-		draftSaveTimer = window.setTimeout(async () => {
-			draftSaveTimer = 0;
-			await withStatus('Saving draft', draftSave());
-			draftScheduleSave();
-		}, 60 * 1000);
-	};
-	const draftSave = async () => {
-		draftCancelSaveTimer();
+	const composeMessage = () => {
 		let replyTo = '';
 		if (replytoViews && replytoViews.length === 1 && replytoViews[0].input.value) {
 			replyTo = replytoViews[0].input.value;
 		}
-		const cm = {
+		return {
 			From: customFrom ? customFrom.value : from.value,
 			To: toViews.map(v => v.input.value).filter(s => s),
 			Cc: ccViews.map(v => v.input.value).filter(s => s),
@@ -2675,7 +3885,96 @@ const compose = (opts, listMailboxes) => {
 			TextBody: body.value,
 			ResponseMessageID: opts.responseMessageID || 0,
 			DraftMessageID: draftMessageID,
+			DraftID: draft?.ID || 0,
+		};
+	};
+	const draftCancelSaveTimer = () => {
+		if (autosaveTimer) {
+			window.clearTimeout(autosaveTimer);
+			autosaveTimer = 0;
+		}
+	};
+	const draftScheduleSave = () => {
+		if (autosaveTimer) {
+			return;
+		}
+		autosaveTimer = window.setTimeout(async () => {
+			autosaveTimer = 0;
+			autosavePromise = draftAutosave();
+			try {
+				await autosavePromise;
+			}
+			catch (err) {
+				console.log('autosaving draft', err);
+			}
+			finally {
+				autosavePromise = Promise.resolve();
+			}
+		}, 5 * 1000);
+	};
+	// Load the compose fields from a draft, e.g. after it was changed elsewhere.
+	const draftLoad = (d) => {
+		draft = d;
+		subject.value = d.Message.Subject;
+		subjectAutosize.dataset.value = subject.value;
+		body.value = d.Message.TextBody;
+		renderDraftAttachments();
+		checkAttachments();
+	};
+	// Save compose state as autosaved draft. If the draft was changed elsewhere, the
+	// user can choose to continue with that version, or overwrite it.
+	const draftAutosave = async () => {
+		const d = {
+			ID: draft?.ID || 0,
+			Version: draft?.Version || 0,
+			Updated: new Date(),
+			Message: composeMessage(),
+			Attachments: [],
 		};
+		try {
+			draft = await client.DraftSave(d);
+		}
+		catch (err) {
+			if (err.code !== 'user:draftConflict') {
+				throw err;
+			}
+			const other = (await client.Drafts() || []).find(x => x.ID === d.ID);
+			if (!other) {
+				throw err;
+			}
+			if (window.confirm('This draft was changed in another window or on another device. Continue with that version? Cancel to overwrite it with this version.')) {
+				draftLoad(other);
+			}
+			else {
+				d.Version = other.Version;
+				draft = await client.DraftSave(d);
+			}
+		}
+	};
+	// Upload the files selected in the file input as attachments of the autosaved
+	// draft, creating the draft if needed.
+	const draftAttachmentsUpload = async () => {
+		const files = await readFiles(attachments.files);
+		attachments.value = '';
+		if (files.length === 0) {
+			return;
+		}
+		draftCancelSaveTimer();
+		await autosavePromise;
+		if (!draft) {
+			await draftAutosave();
+		}
+		for (const f of files) {
+			const a = await client.DraftAttachmentAdd(draft.ID, f);
+			draft.Attachments = [...(draft.Attachments || []), a];
+			renderDraftAttachments();
+		}
+	};
+	const draftSave = async () => {
+		draftCancelSaveTimer();
+		await autosavePromise;
+		await attachmentsPromise;
+		const cm = composeMessage();
 		const mbdrafts = listMailboxes().find(mb => mb.Draft);
 		if (!mbdrafts) {
 			throw new Error('no designated drafts mailbox');
@@ -2691,13 +3990,15 @@ const compose = (opts, listMailboxes) => {
 	};
 	// todo future: on visibilitychange with visibilityState "hidden", use navigator.sendBeacon to save latest modified draft message?
 	// When window is closed, ask user to cancel due to unsaved changes.
-	const unsavedChanges = () => opts.body !== body.value && (!draftMessageID || draftLastText !== body.value);
+	const unsavedChanges = () => (opts.body !== body.value || (!opts.draft && (draft?.Attachments || []).length > 0)) && (!draftMessageID || draftLastText !== body.value);
 	// In Firefox, ctrl-w doesn't seem interceptable when focus is on a button. It is
 	// when focus is on a textarea or not any specific UI element. So this isn't always
 	// triggered. But we still have the beforeunload handler that checks for
 	// unsavedChanges to protect the user in such cases.
 	const cmdClose = async () => {
 		draftCancelSaveTimer();
+		await autosavePromise;
+		await attachmentsPromise;
 		await draftSavePromise;
 		if (unsavedChanges()) {
 			const action = await new Promise((resolve) => {
@@ -2727,6 +4028,11 @@ const compose = (opts, listMailboxes) => {
 				return;
 			}
 		}
+		// The autosaved draft is no longer needed: it was either saved to the Drafts
+		// mailbox or discarded.
+		if (draft) {
+			await withStatus('Removing autosaved draft', client.DraftRemove(draft.ID));
+		}
 		composeElem.remove();
 		composeView = null;
 	};
@@ -2737,27 +4043,9 @@ const compose = (opts, listMailboxes) => {
 	};
 	const submit = async (archive) => {
 		draftCancelSaveTimer();
+		await autosavePromise;
+		await attachmentsPromise;
 		await draftSavePromise;
-		const files = await new Promise((resolve, reject) => {
-			const l = [];
-			if (attachments.files && attachments.files.length === 0) {
-				resolve(l);
-				return;
-			}
-			[...attachments.files].forEach(f => {
-				const fr = new window.FileReader();
-				fr.addEventListener('load', () => {
-					l.push({ Filename: f.name, DataURI: fr.result });
-					if (attachments.files && l.length === attachments.files.length) {
-						resolve(l);
-					}
-				});
-				fr.addEventListener('error', () => {
-					reject(fr.error);
-				});
-				fr.readAsDataURL(f);
-			});
-		});
 		let replyTo = '';
 		if (replytoViews && replytoViews.length === 1 && replytoViews[0].input.value) {
 			replyTo = replytoViews[0].input.value;
@@ -2772,8 +4060,11 @@ const compose = (opts, listMailboxes) => {
 			UserAgent: 'moxwebmail/' + moxversion,
 			Subject: subject.value,
 			TextBody: body.value,
-			Attachments: files,
+			HTMLBody: '',
+			InlineFiles: [],
+			Attachments: templateAttachments,
 			ForwardAttachments: forwardAttachmentPaths.length === 0 ? { MessageID: 0, Paths: [] } : { MessageID: opts.attachmentsMessageItem.Message.ID, Paths: forwardAttachmentPaths },
+			AttachMessageID: attachOriginal?.checked ? opts.responseMessageID || 0 : 0,
 			IsForward: opts.isForward || false,
 			ResponseMessageID: opts.responseMessageID || 0,
 			RequireTLS: requiretls.value === '' ? null : requiretls.value === 'yes',
@@ -2781,10 +4072,17 @@ const compose = (opts, listMailboxes) => {
 			ArchiveThread: archive,
 			ArchiveReferenceMailboxID: opts.archiveReferenceMailboxID || 0,
 			DraftMessageID: draftMessageID,
+			DraftID: draft?.ID || 0,
+			PGPSign: pgpElem.style.display !== 'none' && pgpSign.checked,
+			PGPEncrypt: pgpElem.style.display !== 'none' && pgpEncrypt.checked,
+			PGPPassphrase: pgpPassphrase.value,
 		};
-		await client.MessageSubmit(message);
+		const result = await client.MessageSubmit(message);
 		composeElem.remove();
 		composeView = null;
+		if (result.UndoUntil) {
+			showUndoSend(result);
+		}
 	};
 	const cmdSend = async () => {
 		await withStatus('Sending email', submit(false), fieldset);
@@ -2800,7 +4098,7 @@ const compose = (opts, listMailboxes) => {
 		if (customFrom) {
 			return;
 		}
-		customFrom = dom.input(attr.value(from.value), attr.required(''), focusPlaceholder('Jane <jane@example.org>'));
+		customFrom = dom.input(attr.value(from.value), attr.required(''), focusPlaceholder('Jane <jane@example.org>'), function input() { pgpFromChanged(); });
 		from.replaceWith(customFrom);
 		customFromBtn.remove();
 	};
@@ -2997,9 +4295,55 @@ const compose = (opts, listMailboxes) => {
 	};
 	let noAttachmentsWarning;
 	const checkAttachments = () => {
-		const missingAttachments = !attachments.files?.length && !forwardAttachmentViews.find(v => v.checkbox.checked) && !!body.value.split('\n').find(s => !s.startsWith('>') && s.match(/attach(ed|ment)/));
+		const missingAttachments = (draft?.Attachments || []).length === 0 && !forwardAttachmentViews.find(v => v.checkbox.checked) && !attachOriginal?.checked && templateAttachments.length === 0 && !!body.value.split('\n').find(s => !s.startsWith('>') && s.match(/attach(ed|ment)/));
 		noAttachmentsWarning.style.display = missingAttachments ? '' : 'none';
 	};
+	const renderTemplateAttachments = () => {
+		dom._kids(templateAttachmentsElem, templateAttachments.length === 0 ? [] : [
+			'Template attachments: ',
+			templateAttachments.map(f => [
+				dom.span(f.Filename || '(unnamed)', ' ', dom.clickbutton('Remove', function click() {
+					templateAttachments = templateAttachments.filter(x => x !== f);
+					renderTemplateAttachments();
+					checkAttachments();
+				})),
+				' ',
+			]),
+		]);
+	};
+	const renderDraftAttachments = () => {
+		const l = draft?.Attachments || [];
+		dom._kids(draftAttachmentsElem, l.length === 0 ? [] : [
+			'Attached: ',
+			l.map(a => [
+				dom.span(a.Filename || '(unnamed)', ' ', dom.span('(' + formatSize(a.Size) + ') ', styleClasses.textMild), dom.clickbutton('Remove', async function click(e) {
+					await withStatus('Removing attachment', client.DraftAttachmentRemove(a.ID), e.target);
+					if (draft) {
+						draft.Attachments = (draft.Attachments || []).filter(x => x !== a);
+					}
+					renderDraftAttachments();
+					checkAttachments();
+				})),
+				' ',
+			]),
+		]);
+	};
+	// Insert text of template at cursor, set subject if template has one, and add its
+	// attachments.
+	const applyTemplate = async (ref) => {
+		const to = toViews.map(v => v.input.value).filter(s => s);
+		const ct = await withStatus('Applying template', client.TemplateApply(ref, customFrom ? customFrom.value : from.value, to), fieldset);
+		if (ct.Subject) {
+			subject.value = ct.Subject;
+			subjectAutosize.dataset.value = subject.value;
+		}
+		body.setRangeText(ct.Text, body.selectionStart, body.selectionEnd, 'end');
+		body.focus();
+		templateAttachments.push(...(ct.Attachments || []));
+		renderTemplateAttachments();
+		checkAttachments();
+		draftScheduleSave();
+	};
 	const normalizeUser = (a) => {
 		let user = a.User;
 		const domconf = domainAddressConfigs[a.Domain.ASCII];
@@ -3017,15 +4361,18 @@ const compose = (opts, listMailboxes) => {
 	const addressSelf = (addr) => {
 		return accountAddresses.find(a => a.Domain.ASCII === addr.Domain.ASCII && (a.User === '' || normalizeUser(a) === normalizeUser(addr)));
 	};
-	let haveFrom = false;
+	let haveFrom = !!opts.identity;
+	const identityOptions = accountIdentities.map(ident => dom.option(identityFrom(ident), ident === opts.identity ? attr.selected('') : []));
 	const fromOptions = accountAddresses.filter(a => a.User).map(a => {
-		const selected = opts.from && opts.from.length === 1 && equalAddress(a, opts.from[0]) || loginAddress && equalAddress(a, loginAddress) && (!opts.from || envelopeIdentity(opts.from));
+		const selected = !opts.identity && (opts.from && opts.from.length === 1 && equalAddress(a, opts.from[0]) || loginAddress && equalAddress(a, loginAddress) && (!opts.from || envelopeIdentity(opts.from)));
 		const o = dom.option(formatAddress(a), selected ? attr.selected('') : []);
 		if (selected) {
 			haveFrom = true;
 		}
 		return o;
 	});
+	// Addresses of other accounts that allow sending as or on behalf of them.
+	const delegatedFromOptions = accountDelegated.filter(da => da.Send).map(da => (da.Addresses || []).map(addr => dom.option(addr, attr.title(da.Send === 'onbehalf' ? 'Sent on behalf of account ' + da.Account + ', with Sender header.' : 'Sent as account ' + da.Account + '.'))));
 	if (!haveFrom && opts.from && opts.from.length === 1) {
 		const a = addressSelf(opts.from[0]);
 		if (a) {
@@ -3034,6 +4381,34 @@ const compose = (opts, listMailboxes) => {
 			fromOptions.unshift(o);
 		}
 	}
+	// When another identity is selected as From, its signature replaces the signature
+	// of the previous identity in the body, and its reply-to address is set.
+	let fromIdentity = opts.identity;
+	const fromChanged = () => {
+		const ident = accountIdentities.find(ident => identityFrom(ident) === from.value);
+		if (ident === fromIdentity) {
+			return;
+		}
+		const oldSig = identitySignature(fromIdentity);
+		const i = oldSig ? body.value.lastIndexOf(oldSig) : -1;
+		if (i >= 0) {
+			body.value = body.value.substring(0, i) + identitySignature(ident) + body.value.substring(i + oldSig.length);
+		}
+		if (replytoViews.length === 1 && replytoViews[0].input.value === (fromIdentity?.ReplyTo || '')) {
+			replytoViews[0].input.value = ident?.ReplyTo || '';
+		}
+		else if (replytoViews.length === 0 && ident?.ReplyTo) {
+			newAddrView(ident.ReplyTo, false, false, replytoViews, replyToBtn, replyToCell, replyToRow, true);
+		}
+		fromIdentity = ident;
+		pgpFromChanged();
+	};
+	// OpenPGP options are only shown for From addresses with a private key.
+	const pgpFromChanged = () => {
+		const s = customFrom ? customFrom.value : from.value;
+		const t = /<([^>]*)>\s*$/.exec(s);
+		pgpElem.style.display = pgpAddresses.includes((t ? t[1] : s).trim().toLowerCase()) ? '' : 'none';
+	};
 	let scheduleLink;
 	let scheduleElem;
 	let scheduleTime;
@@ -3099,7 +4474,9 @@ const compose = (opts, listMailboxes) => {
 		flexGrow: '1',
 		display: 'flex',
 		flexDirection: 'column',
-	}), dom.table(style({ width: '100%' }), dom.tr(dom.td(composeTextMildStyle, dom.span('From:')), dom.td(dom.div(css('composeButtonsSpread', { display: 'flex', gap: '1em', justifyContent: 'space-between' }), dom.div(from = dom.select(attr.required(''), style({ width: 'auto' }), fromOptions), ' ', toBtn = dom.clickbutton('To', clickCmd(cmdAddTo, shortcuts)), ' ', ccBtn = dom.clickbutton('Cc', clickCmd(cmdAddCc, shortcuts)), ' ', bccBtn = dom.clickbutton('Bcc', clickCmd(cmdAddBcc, shortcuts)), ' ', replyToBtn = dom.clickbutton('ReplyTo', clickCmd(cmdReplyTo, shortcuts)), ' ', customFromBtn = dom.clickbutton('From', attr.title('Set custom From address/name.'), clickCmd(cmdCustomFrom, shortcuts))), dom.div(listMailboxes().find(mb => mb.Draft) ? [
+	}), dom.table(style({ width: '100%' }), dom.tr(dom.td(composeTextMildStyle, dom.span('From:')), dom.td(dom.div(css('composeButtonsSpread', { display: 'flex', gap: '1em', justifyContent: 'space-between' }), dom.div(from = dom.select(attr.required(''), style({ width: 'auto' }), function change() {
+		fromChanged();
+	}, identityOptions, fromOptions, delegatedFromOptions), ' ', toBtn = dom.clickbutton('To', clickCmd(cmdAddTo, shortcuts)), ' ', ccBtn = dom.clickbutton('Cc', clickCmd(cmdAddCc, shortcuts)), ' ', bccBtn = dom.clickbutton('Bcc', clickCmd(cmdAddBcc, shortcuts)), ' ', replyToBtn = dom.clickbutton('ReplyTo', clickCmd(cmdReplyTo, shortcuts)), ' ', customFromBtn = dom.clickbutton('From', attr.title('Set custom From address/name.'), clickCmd(cmdCustomFrom, shortcuts))), dom.div(listMailboxes().find(mb => mb.Draft) ? [
 		dom.clickbutton('Save', attr.title('Save draft message.'), clickCmd(cmdSave, shortcuts)), ' ',
 	] : [], dom.clickbutton('Close', attr.title('Close window, saving draft message if body has changed or a draft was saved earlier.'), clickCmd(cmdClose, shortcuts)))))), toRow = dom.tr(dom.td('To:', composeTextMildStyle), toCell = dom.td(composeCellStyle)), replyToRow = dom.tr(dom.td('Reply-To:', composeTextMildStyle), replyToCell = dom.td(composeCellStyle)), ccRow = dom.tr(dom.td('Cc:', composeTextMildStyle), ccCell = dom.td(composeCellStyle)), bccRow = dom.tr(dom.td('Bcc:', composeTextMildStyle), bccCell = dom.td(composeCellStyle)), dom.tr(dom.td('Subject:', composeTextMildStyle), dom.td(subjectAutosize = dom.span(dom._class('autosize'), style({ width: '100%' }), // Without 100% width, the span takes minimal width for input, we want the full table cell.
 	subject = dom.input(style({ width: '100%' }), attr.value(opts.subject || ''), attr.required(''), focusPlaceholder('subject...'), function input() {
@@ -3114,7 +4491,7 @@ const compose = (opts, listMailboxes) => {
 		if (e.key === 'Enter') {
 			checkAttachments();
 		}
-	}, !listMailboxes().find(mb => mb.Draft) ? [] : function input() {
+	}, function input() {
 		draftScheduleSave();
 	}), !(opts.attachmentsMessageItem && opts.attachmentsMessageItem.Attachments && opts.attachmentsMessageItem.Attachments.length > 0) ? [] : dom.div(style({ margin: '.5em 0' }), 'Forward attachments: ', forwardAttachmentViews = (opts.attachmentsMessageItem?.Attachments || []).map(a => {
 		const filename = a.Filename || '(unnamed)';
@@ -3129,7 +4506,22 @@ const compose = (opts, listMailboxes) => {
 		return v;
 	}), dom.label(styleClasses.textMild, dom.input(attr.type('checkbox'), function change(e) {
 		forwardAttachmentViews.forEach(v => v.checkbox.checked = e.target.checked);
-	}), ' (Toggle all)')), noAttachmentsWarning = dom.div(style({ display: 'none' }), css('composeNoAttachmentsWarning', { backgroundColor: styles.warningBackgroundColor, padding: '0.15em .25em', margin: '.5em 0' }), 'Message mentions attachments, but no files are attached.'), dom.label(style({ margin: '1ex 0', display: 'block' }), 'Attachments ', attachments = dom.input(attr.type('file'), attr.multiple(''), function change() { checkAttachments(); })), dom.label(style({ margin: '1ex 0', display: 'block' }), attr.title('How to use TLS for message delivery over SMTP:\n\nDefault: Delivery attempts follow the policies published by the recipient domain: Verification with MTA-STS and/or DANE, or optional opportunistic unverified STARTTLS if the domain does not specify a policy.\n\nWith RequireTLS: For sensitive messages, you may want to require verified TLS. The recipient destination domain SMTP server must support the REQUIRETLS SMTP extension for delivery to succeed. It is automatically chosen when the destination domain mail servers of all recipients are known to support it.\n\nFallback to insecure: If delivery fails due to MTA-STS and/or DANE policies specified by the recipient domain, and the content is not sensitive, you may choose to ignore the recipient domain TLS policies so delivery can succeed.'), 'TLS ', requiretls = dom.select(dom.option(attr.value(''), 'Default'), dom.option(attr.value('yes'), 'With RequireTLS'), dom.option(attr.value('no'), 'Fallback to insecure'))), dom.div(scheduleLink = dom.a(attr.href(''), 'Schedule', function click(e) {
+	}), ' (Toggle all)')), !opts.isForward || !opts.responseMessageID ? [] : dom.label(style({ margin: '.5em 0', display: 'block' }), attr.title('Attach the original message in full, with its headers and attachments, as .eml file.'), attachOriginal = dom.input(attr.type('checkbox'), function change() { checkAttachments(); }), ' Attach original message'), templateAttachmentsElem = dom.div(style({ margin: '.5em 0' })), draftAttachmentsElem = dom.div(style({ margin: '.5em 0' })), noAttachmentsWarning = dom.div(style({ display: 'none' }), css('composeNoAttachmentsWarning', { backgroundColor: styles.warningBackgroundColor, padding: '0.15em .25em', margin: '.5em 0' }), 'Message mentions attachments, but no files are attached.'), dom.label(style({ margin: '1ex 0', display: 'block' }), 'Attachments ', attachments = dom.input(attr.type('file'), attr.multiple(''), async function change() {
+		attachmentsPromise = draftAttachmentsUpload();
+		try {
+			await withStatus('Uploading attachments', attachmentsPromise);
+		}
+		finally {
+			attachmentsPromise = Promise.resolve();
+			checkAttachments();
+		}
+	})), templateElem = dom.label(style({ margin: '1ex 0', display: 'none' }), attr.title('Insert the text of a template at the cursor. The subject is replaced if the template has one, and attachments of the template are added. Placeholders like {{recipient.firstname}} are replaced using the first To address.'), 'Template ', templateSelect = dom.select(async function change() {
+		const i = parseInt(templateSelect.value);
+		templateSelect.value = '';
+		if (!isNaN(i)) {
+			await applyTemplate(templateRefs[i]);
+		}
+	})), dom.label(style({ margin: '1ex 0', display: 'block' }), attr.title('How to use TLS for message delivery over SMTP:\n\nDefault: Delivery attempts follow the policies published by the recipient domain: Verification with MTA-STS and/or DANE, or optional opportunistic unverified STARTTLS if the domain does not specify a policy.\n\nWith RequireTLS: For sensitive messages, you may want to require verified TLS. The recipient destination domain SMTP server must support the REQUIRETLS SMTP extension for delivery to succeed. It is automatically chosen when the destination domain mail servers of all recipients are known to support it.\n\nFallback to insecure: If delivery fails due to MTA-STS and/or DANE policies specified by the recipient domain, and the content is not sensitive, you may choose to ignore the recipient domain TLS policies so delivery can succeed.'), 'TLS ', requiretls = dom.select(dom.option(attr.value(''), 'Default'), dom.option(attr.value('yes'), 'With RequireTLS'), dom.option(attr.value('no'), 'Fallback to insecure'))), pgpElem = dom.div(style({ margin: '1ex 0', display: 'none' }), attr.title('Sign the message with the OpenPGP key of the From address, and/or encrypt the message to the OpenPGP keys of the recipients. Keys of recipients are looked up in your account and in the Web Key Directory of their domain. The passphrase is needed for signing with a private key protected by a passphrase.'), dom.label(pgpSign = dom.input(attr.type('checkbox')), ' Sign with OpenPGP'), ' ', dom.label(pgpEncrypt = dom.input(attr.type('checkbox')), ' Encrypt with OpenPGP'), ' ', pgpPassphrase = dom.input(attr.type('password'), attr.autocomplete('off'), attr.placeholder('Passphrase'))), dom.div(scheduleLink = dom.a(attr.href(''), 'Schedule', function click(e) {
 		e.preventDefault();
 		scheduleTime.value = localdatetime(new Date());
 		scheduleTimeChanged();
@@ -3184,6 +4576,28 @@ const compose = (opts, listMailboxes) => {
 	if (!opts.replyto) {
 		replyToRow.style.display = 'none';
 	}
+	if (draft) {
+		const f = draft.Message.From;
+		if ([...from.options].find(o => o.value === f)) {
+			from.value = f;
+		}
+		renderDraftAttachments();
+	}
+	// Templates are optional, the compose window is shown without waiting for them.
+	client.Templates().then(([templates, shared]) => {
+		templateRefs = [
+			...(templates || []).map(t => ({ ID: t.ID, Domain: '', Name: t.Name })),
+			...(shared || []).map(t => ({ ID: 0, Domain: t.Domain, Name: t.Name })),
+		];
+		if (templateRefs.length === 0) {
+			return;
+		}
+		dom._kids(templateSelect, dom.option(attr.value(''), 'Insert template...'), templateRefs.map((ref, i) => dom.option(attr.value('' + i), ref.Name + (ref.Domain ? ' (' + ref.Domain + ')' : ''))));
+		templateElem.style.display = 'block';
+	}, (err) => {
+		log('fetching templates', err);
+	});
+	pgpFromChanged();
 	document.body.appendChild(composeElem);
 	if (toViews.length > 0 && !toViews[0].input.value) {
 		toViews[0].input.focus();
@@ -3198,6 +4612,31 @@ const compose = (opts, listMailboxes) => {
 	};
 	return composeView;
 };
+// Show popup with autosaved drafts, e.g. of compose windows that were open when
+// the browser was closed, to continue composing or remove them.
+const autosavedDraftsPopup = (drafts, listMailboxes) => {
+	const remove = popup(dom.h1('Autosaved drafts'), dom.p('Messages were being composed but not sent.'), dom.table(drafts.map(d => {
+		const row = dom.tr(dom.td(d.Message.Subject || '(no subject)'), dom.td(styleClasses.textMild, (d.Message.To || []).join(', ')), dom.td(styleClasses.textMild, style({ whiteSpace: 'nowrap' }), d.Updated.toLocaleString()), dom.td(style({ whiteSpace: 'nowrap' }), dom.clickbutton('Continue', function click() {
+			remove();
+			const m = d.Message;
+			compose({
+				to: m.To || [],
+				cc: m.Cc || [],
+				bcc: m.Bcc || [],
+				replyto: m.ReplyTo,
+				subject: m.Subject,
+				body: m.TextBody,
+				responseMessageID: m.ResponseMessageID,
+				draftMessageID: m.DraftMessageID,
+				draft: d,
+			}, listMailboxes);
+		}), ' ', dom.clickbutton('Remove', async function click(e) {
+			await withStatus('Removing autosaved draft', client.DraftRemove(d.ID), e.target);
+			row.remove();
+		})));
+		return row;
+	})));
+};
 // Show popover to edit labels for msgs.
 const labelsPopover = (e, msgs, possibleLabels) => {
 	if (msgs.length === 0) {
@@ -3217,7 +4656,7 @@ const labelsPopover = (e, msgs, possibleLabels) => {
 			await withStatus('Adding label', client.FlagsAdd(msgIDs, [l]), e.target);
 			activeLabels.push(l);
 		}
-	}), ' ', dom.span(styleClasses.keyword, l))))), dom.hr(style({ margin: '2ex 0' })), dom.form(async function submit(e) {
+	}), ' ', dom.span(styleClasses.keyword, keywordColor(l), l))))), dom.hr(style({ margin: '2ex 0' })), dom.form(async function submit(e) {
 		e.preventDefault();
 		await withStatus('Adding new label', client.FlagsAdd(msgIDs, [newlabel.value]), fieldsetnew);
 		remove();
@@ -3371,7 +4810,7 @@ const newMsgitemView = (mi, msglistView, otherMailbox, listMailboxes, receivedTi
 		// Keywords are normally shown per message. For collapsed threads, we show the
 		// keywords of the thread root message as normal, and any additional keywords from
 		// children in a way that draws less attention.
-		const keywords = (m.Keywords || []).map(kw => dom.span(styleClasses.keyword, kw));
+		const keywords = (m.Keywords || []).map(kw => dom.span(styleClasses.keyword, keywordColor(kw), kw));
 		if (msgitemView.isCollapsedThreadRoot()) {
 			const keywordsSeen = new Set();
 			for (const kw of (m.Keywords || [])) {
@@ -3381,7 +4820,7 @@ const newMsgitemView = (mi, msglistView, otherMailbox, listMailboxes, receivedTi
 				for (const kw of (miv.messageitem.Message.Keywords || [])) {
 					if (!keywordsSeen.has(kw)) {
 						keywordsSeen.add(kw);
-						keywords.push(dom.span(styleClasses.keyword, dom._class('keywordCollapsed'), kw));
+						keywords.push(dom.span(styleClasses.keyword, dom._class('keywordCollapsed'), keywordColor(kw), kw));
 					}
 				}
 			}
@@ -3683,65 +5122,27 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		parsedMessageResolve = resolve;
 		parsedMessageReject = reject;
 	});
-	const react = async (to, cc, bcc, forward) => {
-		const pm = await parsedMessagePromise;
-		let body = '';
+	// The server prepares the recipients, subject and quoted text of a reply or
+	// forward. Selected text is quoted instead of the message text.
+	const react = async (mode) => {
 		const sel = window.getSelection();
-		let haveSel = false;
-		if (sel && sel.toString()) {
-			body = sel.toString();
-			haveSel = true;
-		}
-		else if (pm.Texts && pm.Texts.length > 0) {
-			body = pm.Texts[0];
-		}
-		body = body.replace(/\r/g, '').replace(/\n\n\n\n*/g, '\n\n').trim();
-		let editOffset = 0;
-		if (forward) {
-			let prefix = `\n\n---- Forwarded Message ----\n`;
-			const keys = ['Subject', 'Date', 'From', 'Reply-To', 'To', 'Cc'];
-			const padspace = (s, size) => s + ' '.repeat(size - s.length);
-			for (const k of keys) {
-				for (const v of (pm.Headers?.[k] || [])) {
-					prefix += padspace(k + ':', 10) + v + '\n';
-				}
-			}
-			body = prefix + '\n' + body;
-		}
-		else {
-			body = body.split('\n').map(line => '> ' + line).join('\n');
-			let sig = accountSettings?.Signature || '';
-			if (!accountSettings?.Quoting && haveSel || accountSettings?.Quoting === api.Quoting.Bottom) {
-				body += '\n\n';
-				editOffset = body.length;
-				body += '\n\n' + sig;
-			}
-			else {
-				let onWroteLine = '';
-				if (mi.Envelope.Date && mi.Envelope.From && mi.Envelope.From.length === 1) {
-					const from = mi.Envelope.From[0];
-					const name = from.Name || formatEmail(from);
-					const datetime = mi.Envelope.Date.toLocaleDateString(undefined, { weekday: "short", year: "numeric", month: "short", day: "numeric" }) + ' at ' + mi.Envelope.Date.toLocaleTimeString();
-					onWroteLine = 'On ' + datetime + ', ' + name + ' wrote:\n';
-				}
-				body = '\n\n' + sig + '\n' + onWroteLine + body;
-			}
-		}
-		const subjectPrefix = forward ? 'Fwd:' : 'Re:';
-		let subject = mi.Envelope.Subject || '';
-		subject = (RegExp('^' + subjectPrefix, 'i').test(subject) ? '' : subjectPrefix + ' ') + subject;
+		const rc = await withStatus('Preparing response', client.MessageResponse(m.ID, mode, sel ? sel.toString() : ''));
+		const forward = mode === api.ResponseMode.ResponseForward;
+		const ident = findIdentity(mi.Envelope.To || []);
 		const opts = {
 			from: mi.Envelope.To || undefined,
-			to: to.map(a => formatAddress(a)),
-			cc: cc.map(a => formatAddress(a)),
-			bcc: bcc.map(a => formatAddress(a)),
-			subject: subject,
-			body: body,
+			identity: ident,
+			replyto: rc.ReplyTo,
+			to: rc.To || [],
+			cc: rc.Cc || [],
+			bcc: rc.Bcc || [],
+			subject: rc.Subject,
+			body: rc.TextBody,
 			isForward: forward,
 			attachmentsMessageItem: forward ? mi : undefined,
 			responseMessageID: m.ID,
 			isList: m.IsMailingList,
-			editOffset: editOffset,
+			editOffset: rc.EditOffset,
 			// For "send and archive", we only move messages from the current open mailbox
 			// (fallback to mailbox of response message for search results) to the archive
 			// mailbox. We don't want to move messages in other mailboxes, like Sent, Trash, or
@@ -3750,47 +5151,15 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		};
 		compose(opts, listMailboxes);
 	};
-	const reply = async (all) => {
-		const contains = (l, a) => !!l.find(e => equalAddress(e, a));
-		let to = [];
-		let cc = [];
-		let bcc = [];
-		if ((mi.Envelope.From || []).length === 1 && envelopeIdentity(mi.Envelope.From || [])) {
-			// Replying to our own message, copy the original cc/bcc.
-			to = mi.Envelope.To || [];
-		}
-		else {
-			if (mi.Envelope.ReplyTo && mi.Envelope.ReplyTo.length > 0) {
-				to = mi.Envelope.ReplyTo;
-			}
-			else {
-				to = mi.Envelope.From || [];
-			}
-			if (all) {
-				for (const a of (mi.Envelope.To || [])) {
-					if (!contains(to, a) && !envelopeIdentity([a])) {
-						to.push(a);
-					}
-				}
-			}
-		}
-		if (all) {
-			cc = mi.Envelope.CC || [];
-			bcc = mi.Envelope.BCC || [];
-		}
-		cc = cc.filter((a, i) => !envelopeIdentity([a]) && !contains(to, a) && !contains(cc.slice(0, i), a));
-		bcc = bcc.filter(a => !envelopeIdentity([a]));
-		await react(to, cc, bcc, false);
-	};
-	const cmdForward = async () => { react([], [], [], true); };
+	const cmdForward = async () => { await react(api.ResponseMode.ResponseForward); };
 	const cmdReplyList = async () => {
 		const pm = await parsedMessagePromise;
 		if (pm.ListReplyAddress) {
-			await react([pm.ListReplyAddress], [], [], false);
+			await react(api.ResponseMode.ResponseReplyList);
 		}
 	};
-	const cmdReply = async () => { await reply(false); };
-	const cmdReplyAll = async () => { await reply(true); };
+	const cmdReply = async () => { await react(api.ResponseMode.ResponseReply); };
+	const cmdReplyAll = async () => { await react(api.ResponseMode.ResponseReplyAll); };
 	const cmdPrint = async () => {
 		if (urlType) {
 			window.open('msg/' + m.ID + '/msg' + urlType + '#print', '_blank');
@@ -3818,6 +5187,10 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		window.open('msg/' + m.ID + '/viewtext/' + [0, ...path].join('.'), '_blank');
 	};
 	const cmdDownloadRaw = async () => { window.open('msg/' + m.ID + '/rawdl', '_blank'); };
+	const cmdOpenPrintable = async () => {
+		window.open('msg/' + m.ID + '/print' + (urlType === 'html' || urlType === 'htmlexternal' ? '?html=true' : ''), '_blank');
+	};
+	const cmdDownloadPDF = async () => { window.open('msg/' + m.ID + '/print.pdf', '_blank'); };
 	const cmdViewAttachments = async () => {
 		if (attachments.length > 0) {
 			view(attachments[0]);
@@ -3907,6 +5280,20 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		const mimepart = (p) => dom.li((p.MediaType + '/' + p.MediaSubType).toLowerCase(), p.ContentTypeParams ? ' ' + JSON.stringify(p.ContentTypeParams) : [], p.Parts && p.Parts.length === 0 ? [] : dom.ul(css('internalsList', { listStyle: 'disc', marginLeft: '1em' }), (p.Parts || []).map(pp => mimepart(pp))));
 		popup(css('popupInternals', { display: 'flex', gap: '1em' }), dom.div(dom.h1('Mime structure'), dom.ul(css('internalsList', { listStyle: 'disc', marginLeft: '1em' }), mimepart(pm.Part))), dom.div(css('internalsMessage', { whiteSpace: 'pre-wrap', tabSize: 4, maxWidth: '50%' }), dom.h1('Message'), JSON.stringify(m, undefined, '\t')), dom.div(css('internalsParts', { whiteSpace: 'pre-wrap', tabSize: 4, maxWidth: '50%' }), dom.h1('Part'), JSON.stringify(pm.Part, undefined, '\t')));
 	};
+	const blockSender = async (domain) => {
+		const from = (mi.Envelope.From || [])[0];
+		if (!from) {
+			window.alert('Message has no From address.');
+			return;
+		}
+		const fromDomain = from.Domain.Unicode || from.Domain.ASCII;
+		if (!window.confirm('Reject future messages from ' + (domain ? 'domain ' + fromDomain : from.User + '@' + fromDomain) + '? Blocked senders can be managed in the account settings.')) {
+			return;
+		}
+		await withStatus('Blocking sender', client.SenderBlock(m.ID, domain));
+	};
+	const cmdBlockSender = async () => { await blockSender(false); };
+	const cmdBlockSenderDomain = async () => { await blockSender(true); };
 	const cmdUp = async () => { msgscrollElem.scrollTo({ top: msgscrollElem.scrollTop - 3 * msgscrollElem.getBoundingClientRect().height / 4, behavior: 'smooth' }); };
 	const cmdDown = async () => { msgscrollElem.scrollTo({ top: msgscrollElem.scrollTop + 3 * msgscrollElem.getBoundingClientRect().height / 4, behavior: 'smooth' }); };
 	const cmdHome = async () => { msgscrollElem.scrollTo({ top: 0 }); };
@@ -3940,9 +5327,9 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		M: msglistView.cmdMarkUnread,
 	};
 	let urlType; // text, html, htmlexternal; for opening in new tab/print
-	let msgbuttonElem, msgheaderElem, msgattachmentElem, msgmodeElem;
+	let msgbuttonElem, msgheaderElem, msgattachmentElem, msgmodeElem, msgpgpElem, msgsmimeElem, msgcalendarElem, msgunsubscribeElem, msgauthElem, msgsenderwarningElem;
 	let msgheaderFullElem; // Full headers, when enabled.
-	const msgmetaElem = dom.div(css('msgmeta', { backgroundColor: styles.backgroundColorMild, borderBottom: '5px solid', borderBottomColor: ['white', 'black'], maxHeight: '90%', overflowY: 'auto' }), attr.role('region'), attr.arialabel('Buttons and headers for message'), msgbuttonElem = dom.div(), dom.div(attr.arialive('assertive'), dom.table(styleClasses.msgHeaders, msgheaderElem = dom.tbody()), msgheaderFullElem = dom.table(), msgattachmentElem = dom.div(), msgmodeElem = dom.div()), 
+	const msgmetaElem = dom.div(css('msgmeta', { backgroundColor: styles.backgroundColorMild, borderBottom: '5px solid', borderBottomColor: ['white', 'black'], maxHeight: '90%', overflowY: 'auto' }), attr.role('region'), attr.arialabel('Buttons and headers for message'), msgbuttonElem = dom.div(), dom.div(attr.arialive('assertive'), dom.table(styleClasses.msgHeaders, msgheaderElem = dom.tbody()), msgheaderFullElem = dom.table(), msgsenderwarningElem = dom.div(), msgattachmentElem = dom.div(), msgmodeElem = dom.div(), msgpgpElem = dom.div(), msgsmimeElem = dom.div(), msgcalendarElem = dom.div(), msgunsubscribeElem = dom.div(), msgauthElem = dom.div()), 
 	// Explicit separator that separates headers from body, to
 	// prevent HTML messages from faking UI elements.
 	dom.div(css('headerBodySeparator', { height: '2px', backgroundColor: styles.borderColor })));
@@ -3963,11 +5350,15 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 			popover(e.target, { transparent: true }, dom.div(css('popupMore', { display: 'flex', flexDirection: 'column', gap: '.5ex', textAlign: 'right' }), [
 				dom.clickbutton('Print', attr.title('Print message, opens in new tab and opens print dialog.'), clickCmd(cmdPrint, shortcuts)),
 				dom.clickbutton('Mark Not Junk', attr.title('Mark as not junk, causing this message to be used in spam classification of new incoming messages.'), clickCmd(msglistView.cmdMarkNotJunk, shortcuts)),
+				dom.clickbutton('Block sender', attr.title('Reject future messages from the From address of this message.'), clickCmd(cmdBlockSender, shortcuts)),
+				dom.clickbutton('Block sender domain', attr.title('Reject future messages from the domain of the From address of this message.'), clickCmd(cmdBlockSenderDomain, shortcuts)),
 				dom.clickbutton('Mark Read', clickCmd(msglistView.cmdMarkRead, shortcuts)),
 				dom.clickbutton('Mark Unread', clickCmd(msglistView.cmdMarkUnread, shortcuts)),
 				dom.clickbutton('Mute thread', clickCmd(msglistView.cmdMute, shortcuts)),
 				dom.clickbutton('Unmute thread', clickCmd(msglistView.cmdUnmute, shortcuts)),
 				dom.clickbutton('Open in new tab', clickCmd(cmdOpenNewTab, shortcuts)),
+				dom.clickbutton('Open printable version in new tab', attr.title('Self-contained document with headers and the displayed part, without external resources.'), clickCmd(cmdOpenPrintable, shortcuts)),
+				dom.clickbutton('Download as PDF', attr.title('PDF with headers and text of the message, for archiving.'), clickCmd(cmdDownloadPDF, shortcuts)),
 				dom.clickbutton('Download raw original message', clickCmd(cmdDownloadRaw, shortcuts)),
 				dom.clickbutton('Export as ...', function click(e) {
 					popoverExport(e.target, '', [m.ID]);
@@ -4067,7 +5458,9 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 			const eye = '👁';
 			const dl = '⤓'; // \u2913, actually ⭳ \u2b73 would be better, but in fewer fonts (at least macos)
 			const dlurl = 'msg/' + m.ID + '/download/' + [0].concat(a.Path || []).join('.');
-			const viewbtn = dom.clickbutton(eye, viewable ? ' ' + name : style({ padding: '0px 0.25em' }), attr.title('View this file. Size: ' + size), style({ lineHeight: '1.5' }), function click() {
+			// Small server-generated thumbnail, removed if none could be generated, e.g. for PDFs without image.
+			const thumb = isImage(a) || isPDF(a) ? dom.img(attr.src('msg/' + m.ID + '/thumbnail/' + [0].concat(a.Path || []).join('.') + '?size=small'), style({ maxHeight: '1.5em', maxWidth: '3em', verticalAlign: 'middle', marginRight: '.25em' }), function error(e) { e.target.remove(); }) : [];
+			const viewbtn = dom.clickbutton(eye, viewable ? [' ', thumb, name] : style({ padding: '0px 0.25em' }), attr.title('View this file. Size: ' + size), style({ lineHeight: '1.5' }), function click() {
 				view(a);
 			});
 			const dlbtn = dom.a(dom._class('button'), attr.download(''), attr.href(dlurl), dl, viewable ? style({ padding: '0px 0.25em' }) : ' ' + name, attr.title('Download this file. Size: ' + size), style({ lineHeight: '1.5' }));
@@ -4090,7 +5483,9 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		urlType = 'text';
 		const elem = dom.div(dom._class('mono', 'textmulti'), style({ whiteSpace: 'pre-wrap' }), (pm.Texts || []).map(t => renderText(t.replace(/\r\n/g, '\n'))), (mi.Attachments || []).filter(f => isImage(f)).map(f => {
 			const pathStr = [0].concat(f.Path || []).join('.');
-			return dom.div(dom.div(css('msgAttachmentBox', { flexGrow: 1, display: 'flex', alignItems: 'center', justifyContent: 'center', maxHeight: 'calc(100% - 50px)' }), dom.img(attr.src('msg/' + m.ID + '/view/' + pathStr), attr.title(f.Filename), css('msgInlineImage', { boxShadow: styles.boxShadow, maxWidth: '100%', maxHeight: '100%' }))));
+			// Scaled down on the server, except GIFs which may be animated.
+			const isGIF = (f.Part.MediaType + '/' + f.Part.MediaSubType).toLowerCase() === 'image/gif';
+			return dom.div(dom.div(css('msgAttachmentBox', { flexGrow: 1, display: 'flex', alignItems: 'center', justifyContent: 'center', maxHeight: 'calc(100% - 50px)' }), dom.img(attr.src(isGIF ? 'msg/' + m.ID + '/view/' + pathStr : 'msg/' + m.ID + '/thumbnail/' + pathStr + '?size=large'), attr.title(f.Filename), css('msgInlineImage', { boxShadow: styles.boxShadow, maxWidth: '100%', maxHeight: '100%' }))));
 		}));
 		dom._kids(msgcontentElem);
 		dom._kids(msgscrollElem, elem);
@@ -4107,6 +5502,175 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 		dom._kids(msgcontentElem, dom.iframe(attr.tabindex('0'), attr.title('HTML version of message with images inlined and with external resources loaded.'), attr.src('msg/' + m.ID + '/' + urlType), css('msgIframeHTML', { position: 'absolute', width: '100%', height: '100%' })));
 		renderAttachments(); // Rerender opaciy on inline images.
 	};
+	// Signatures of OpenPGP messages are verified automatically. Decrypting may need
+	// the passphrase of the private key, so is done on request.
+	const loadPGP = () => {
+		if (!mi.IsSigned && !mi.IsEncrypted) {
+			return;
+		}
+		const pgpStyle = css('msgPGP', { padding: '0 .15em', margin: '0 .25em 0 0' });
+		let passphrase;
+		let fieldset;
+		const render = (r) => {
+			let status;
+			let ok = false;
+			if (!r.Signed) {
+				status = 'Not signed';
+			}
+			else if (r.SignatureValid) {
+				ok = r.SignerFrom;
+				status = 'Valid OpenPGP signature by key ' + r.SignerKeyID + ((r.SignerAddresses || []).length > 0 ? ' of ' + (r.SignerAddresses || []).join(', ') : '') + (r.SignerKeySource === 'wkd' ? ', from Web Key Directory' : '');
+				if (!r.SignerFrom) {
+					status += ', but key does not have the From address';
+				}
+			}
+			else {
+				status = 'OpenPGP signature not verified: ' + r.SignatureError;
+			}
+			dom._kids(msgpgpElem, dom.div(dom._class('pad'), r.Decrypted ? dom.span('Decrypted', pgpStyle) : [], dom.span(status, pgpStyle, ok ? [] : style({ backgroundColor: styles.warningBackgroundColor }))));
+			if (r.Decrypted) {
+				urlType = 'text';
+				dom._kids(msgscrollElem, dom.div(dom._class('mono', 'textmulti'), style({ whiteSpace: 'pre-wrap' }), (r.Texts || []).map(t => renderText(t.replace(/\r\n/g, '\n')))));
+				dom._kids(msgcontentElem, msgscrollElem);
+			}
+		};
+		if (!mi.IsEncrypted) {
+			(async () => {
+				render(await withStatus('Verifying OpenPGP signature', client.MessagePGP(m.ID, '')));
+			})();
+			return;
+		}
+		dom._kids(msgpgpElem, dom.div(dom._class('pad'), dom.form(async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			render(await withStatus('Decrypting message', client.MessagePGP(m.ID, passphrase.value), fieldset));
+		}, fieldset = dom.fieldset(dom.span('Encrypted with OpenPGP', pgpStyle), ' ', passphrase = dom.input(attr.type('password'), attr.autocomplete('off'), attr.placeholder('Passphrase'), attr.title('Passphrase of the private key, if it is protected with a passphrase.')), ' ', dom.submitbutton('Decrypt')))));
+	};
+	// Meeting invitations are shown with their details, and buttons to respond to the
+	// organizer.
+	const renderCalendar = () => {
+		const c = mi.Calendar;
+		if (!c) {
+			return;
+		}
+		const dateOpts = { weekday: 'short', year: 'numeric', month: 'short', day: 'numeric' };
+		const formatTime = (d) => c.AllDay ? d.toLocaleDateString(undefined, { ...dateOpts, timeZone: 'UTC' }) : d.toLocaleDateString(undefined, dateOpts) + ' ' + d.toLocaleTimeString(undefined, { hour: 'numeric', minute: '2-digit' });
+		const formatAttendee = (a) => a.Name && a.Address ? a.Name + ' <' + a.Address + '>' : (a.Name || a.Address);
+		const title = { REQUEST: 'Invitation', REPLY: 'Reply to invitation', CANCEL: 'Canceled event' }[c.Method] || 'Event';
+		let fieldset;
+		const reply = async (status) => {
+			await withStatus('Sending reply to organizer', client.CalendarReply(m.ID, status), fieldset);
+		};
+		dom._kids(msgcalendarElem, dom.div(dom._class('pad'), dom.div(dom.b(title + ': ' + (c.Summary || '(no summary)'))), dom.div('When: ' + formatTime(c.Start) + (c.End ? ' - ' + formatTime(c.End) : '')), c.Location ? dom.div('Where: ' + c.Location) : [], c.Organizer.Name || c.Organizer.Address ? dom.div('Organizer: ' + formatAttendee(c.Organizer)) : [], (c.Attendees || []).length > 0 ? dom.div('Attendees: ' + (c.Attendees || []).map(a => formatAttendee(a) + (a.Status ? ' (' + a.Status.toLowerCase() + ')' : '')).join(', ')) : [], c.Method === 'REQUEST' ? fieldset = dom.fieldset(style({ marginTop: '.5ex' }), dom.clickbutton('Accept', async function click() { await reply('ACCEPTED'); }), ' ', dom.clickbutton('Tentative', async function click() { await reply('TENTATIVE'); }), ' ', dom.clickbutton('Decline', async function click() { await reply('DECLINED'); })) : []));
+	};
+	renderCalendar();
+	// Messages from mailing lists with a List-Unsubscribe header can be unsubscribed
+	// from, with one-click unsubscribe or by sending a message, after confirmation.
+	const renderUnsubscribe = () => {
+		const u = mi.Unsubscribe;
+		if (!u) {
+			return;
+		}
+		const from = mi.Envelope.From && mi.Envelope.From.length > 0 ? formatEmail(mi.Envelope.From[0]) : '';
+		let fieldset;
+		const unsubscribe = async () => {
+			const history = await withStatus('Looking up unsubscribe history', client.UnsubscribeHistory(from), fieldset);
+			let text = 'Unsubscribe from messages from ' + from + '?';
+			if (u.OneClick) {
+				text += '\n\nA request will be made to ' + u.URL;
+			}
+			else if (u.Mailto) {
+				text += '\n\nAn unsubscribe message will be sent to ' + u.Mailto.replace(/^mailto:/i, '').replace(/\?.*$/, '');
+			}
+			else {
+				text += '\n\nThe unsubscribe page of the sender will be opened: ' + u.URL;
+			}
+			if (history && history.length > 0) {
+				const h = history[0];
+				text += '\n\nYou already unsubscribed on ' + h.Time.toLocaleString() + (h.Error ? ', but it failed: ' + h.Error : '') + '.';
+			}
+			if (!window.confirm(text)) {
+				return;
+			}
+			if (u.OneClick || u.Mailto) {
+				await withStatus('Unsubscribing', client.MessageUnsubscribe(m.ID, u.OneClick ? 'oneclick' : 'mailto'), fieldset);
+				dom._kids(msgunsubscribeElem, dom.div(dom._class('pad'), 'Unsubscribed from messages from ' + from + '.'));
+			}
+			else {
+				window.open(u.URL, '_blank', 'noopener,noreferrer');
+			}
+		};
+		dom._kids(msgunsubscribeElem, dom.div(dom._class('pad'), fieldset = dom.fieldset('This message is from a mailing list. ', dom.clickbutton('Unsubscribe', attr.title('Unsubscribe using the List-Unsubscribe header of the message.'), async function click() { await unsubscribe(); }))));
+	};
+	renderUnsubscribe();
+	// Results of the SPF, DKIM and DMARC checks done during delivery, and for
+	// messages in the Junk mailbox the reason they were delivered there.
+	const renderAuth = () => {
+		const a = mi.Auth;
+		if (!a) {
+			return;
+		}
+		const badStyle = style({ backgroundColor: styles.warningBackgroundColor });
+		const badge = (name, c, title) => {
+			const result = c.Result || 'none';
+			return dom.span(css('msgAuthBadge', { padding: '0 .25em', marginRight: '.5em', border: '1px solid', borderColor: styles.borderColor, borderRadius: '.25em' }), ['fail', 'softfail', 'permerror'].includes(result) ? badStyle : [], name + ' ' + result, attr.title(title + (c.Domain ? '\nDomain: ' + c.Domain : '')));
+		};
+		const dkim = a.DKIM || [];
+		const inJunk = !!listMailboxes().find(mb => mb.ID === m.MailboxID && mb.Junk);
+		dom._kids(msgauthElem, dom.div(dom._class('pad'), badge('SPF', a.SPF, 'Whether the IP address of the sending mail server is allowed to send for the domain.'), dkim.length === 0 ?
+			badge('DKIM', { Result: 'none', Domain: '' }, 'Message was not DKIM-signed.') :
+			dkim.map(c => badge('DKIM', c, 'Whether a DKIM signature of the message verified.')), badge('DMARC', a.DMARC, 'Whether the domain of the From address is aligned with a passing SPF or DKIM domain, and the result of the DMARC policy.'), a.BIMI.Result ? badge('BIMI', a.BIMI, 'Whether the domain publishes a logo, only evaluated for messages that pass DMARC with an enforced policy.') : [], a.BIMILogoPath ? dom.img(attr.src(a.BIMILogoPath), attr.title('Logo of the sender domain, for a message that passed DMARC with an enforced policy.\nDomain: ' + a.BIMI.Domain), style({ height: '1.5em', width: '1.5em', verticalAlign: 'middle' }), function error(e) { e.target.remove(); }) : [], inJunk && a.Reason ? dom.div('Delivered to Junk, reason: ' + a.Reason, ' ', dom.clickbutton('Why?', attr.title('Show the signals and the words of the junk filter that caused the message to be marked as junk.'), async function click(e) {
+			await showJunkVerdict(m.ID, e.target);
+		}), (a.ReasonDetails || []).length > 0 ? dom.ul(css('msgAuthReasons', { margin: '.25em 0' }), (a.ReasonDetails || []).map(s => dom.li(s))) : []) : []));
+	};
+	renderAuth();
+	// Warnings about a sender possibly impersonating a contact or known domain.
+	const renderSenderWarnings = () => {
+		const l = mi.SenderWarnings || [];
+		if (l.length === 0) {
+			return;
+		}
+		dom._kids(msgsenderwarningElem, dom.div(dom._class('pad'), css('msgSenderWarning', { backgroundColor: styles.warningBackgroundColor }), attr.role('alert'), dom.b('Be careful with this message. '), l.map((w, i) => [i > 0 ? ' ' : [], w.Text])));
+	};
+	renderSenderWarnings();
+	// For S/MIME signed messages, the status of the signature is shown. The key of the
+	// first valid signature from the From address is pinned, with a warning for later
+	// messages signed with another key.
+	const loadSMIME = (pm) => {
+		const s = mi.SMIME;
+		if (!s) {
+			return;
+		}
+		const changed = !!pm.SMIMEPinned && pm.SMIMEPinned !== s.Fingerprint;
+		let status;
+		if (!s.Valid) {
+			status = 'S/MIME signature not valid: ' + s.Error;
+		}
+		else if (!s.SignerFrom) {
+			status = 'Valid S/MIME signature, but certificate is not for the From address, only for ' + (s.SignerAddresses || []).join(', ');
+		}
+		else {
+			status = 'Valid S/MIME signature by ' + (s.SignerAddresses || []).join(', ');
+		}
+		const c = pm.SMIMECertificate;
+		const warningStyle = style({ backgroundColor: styles.warningBackgroundColor });
+		dom._kids(msgsmimeElem, dom.div(dom._class('pad'), dom.span(status, css('msgSMIME', { padding: '0 .15em' }), s.Valid && s.SignerFrom && !changed ? [] : warningStyle, c ? attr.title('Subject: ' + c.Subject + '\nIssuer: ' + c.Issuer + '\nSerial number: ' + c.SerialNumber + '\nValid from ' + c.NotBefore.toISOString() + ' until ' + c.NotAfter.toISOString() + ((c.Chain || []).length > 0 ? '\nChain: ' + (c.Chain || []).join(' → ') : '')) : []), changed ? [
+			' ',
+			dom.span('The sender previously signed with another key.', warningStyle),
+			' ',
+			dom.clickbutton('Accept new key', attr.title('Pin the key that signed this message for the sender, future messages signed with this key are shown without warning.'), async function click(e) {
+				await withStatus('Pinning S/MIME key', client.SMIMEPin(m.ID), e.target);
+				pm.SMIMEPinned = s.Fingerprint;
+				loadSMIME(pm);
+			}),
+		] : []));
+		if (!pm.SMIMEPinned && s.Valid && s.SignerFrom) {
+			pm.SMIMEPinned = s.Fingerprint;
+			(async () => {
+				await withStatus('Pinning S/MIME key', client.SMIMEPin(m.ID));
+			})();
+		}
+	};
 	const mv = {
 		root: root,
 		messageitem: mi,
@@ -4171,15 +5735,17 @@ const newMsgView = (miv, msglistView, listMailboxes, possibleLabels, messageLoad
 				loadHTML();
 			}
 		}
+		loadPGP();
+		loadSMIME(pm);
 		messageLoaded();
-		if (!miv.messageitem.Message.Seen) {
+		if (!miv.messageitem.Message.Seen && !impersonationReadOnly) {
 			window.setTimeout(async () => {
 				if (!miv.messageitem.Message.Seen && miv.messageitem.Message.ID === msglistView.activeMessageID()) {
 					await withStatus('Marking current message as read', client.FlagsAdd([miv.messageitem.Message.ID], ['\\seen']));
 				}
 			}, 500);
 		}
-		if (!miv.messageitem.Message.Junk && !miv.messageitem.Message.Notjunk) {
+		if (!miv.messageitem.Message.Junk && !miv.messageitem.Message.Notjunk && !impersonationReadOnly) {
 			window.setTimeout(async () => {
 				const mailboxIsReject = () => !!listMailboxes().find(mb => mb.ID === miv.messageitem.Message.MailboxID && mb.Name === rejectsMailbox);
 				if (!miv.messageitem.Message.Junk && !miv.messageitem.Message.Notjunk && miv.messageitem.Message.Seen && miv.messageitem.Message.ID === msglistView.activeMessageID() && !mailboxIsReject()) {
@@ -5519,7 +7085,10 @@ const popoverExport = (reference, mailboxName, messageIDs) => {
 	}), ' ', dom.submitbutton('tar', function click() {
 		format.value = 'maildir';
 		archive.value = 'tar';
-	})))));
+	})), messageIDs ? dom.div('PDF ', dom.submitbutton('pdf', attr.title('Export as a single PDF document, with the headers and text of each message, and a list of its attachments.'), function click() {
+		format.value = 'pdf';
+		archive.value = 'none';
+	})) : [])));
 };
 const newMailboxView = (xmb, mailboxlistView, otherMailbox) => {
 	const plusbox = '⊞';
@@ -5552,6 +7121,10 @@ const newMailboxView = (xmb, mailboxlistView, otherMailbox) => {
 			remove();
 			const mailboxIDs = [mbv.mailbox.ID, ...mailboxlistView.mailboxes().filter(mb => mb.Name.startsWith(mbv.mailbox.Name + '/')).map(mb => mb.ID)];
 			await withStatus('Marking mailboxes as read', client.MailboxesMarkRead(mailboxIDs));
+		})), dom.div(dom.clickbutton('Run rules', attr.title('Apply the rules for incoming messages to the messages in this mailbox. Messages are not forwarded.'), async function click() {
+			remove();
+			const n = await withStatus('Running rules', client.RuleRun(mbv.mailbox.ID));
+			window.alert(n + ' message(s) matched a rule.');
 		})), dom.div(dom.clickbutton('Create mailbox', attr.title('Create new mailbox within this mailbox.'), function click(e) {
 			let fieldset;
 			let name;
@@ -5738,9 +7311,10 @@ const newMailboxView = (xmb, mailboxlistView, otherMailbox) => {
 	};
 	return mbv;
 };
-const newMailboxlistView = (msglistView, requestNewView, updatePageTitle, setLocationHash, unloadSearch, otherMailbox) => {
+const newMailboxlistView = (msglistView, requestNewView, updatePageTitle, setLocationHash, unloadSearch, otherMailbox, openSearch) => {
 	let mailboxViews = [];
 	let mailboxViewActive;
+	let savedSearches = [];
 	// Reorder mailboxes and assign new short names and indenting. Called after changing the list.
 	const updateMailboxNames = () => {
 		const draftmb = mailboxViews.find(mbv => mbv.mailbox.Draft)?.mailbox;
@@ -5837,6 +7411,29 @@ const newMailboxlistView = (msglistView, requestNewView, updatePageTitle, setLoc
 	};
 	const root = dom.div();
 	const mailboxesElem = dom.div();
+	const savedSearchesElem = dom.div();
+	const renderSavedSearches = () => {
+		if (savedSearches.length === 0) {
+			dom._kids(savedSearchesElem);
+			return;
+		}
+		dom._kids(savedSearchesElem, dom.div(style({ marginTop: '1ex' }), attr.role('region'), attr.arialabel('Saved searches'), dom.h1('Saved searches', style({ fontSize: 'inherit' })), savedSearches.map(ss => dom.div(dom._class('mailboxItem'), attr.tabindex('0'), attr.title('Search: ' + ss.Search + '\nTotal messages: ' + ss.Total), async function click() {
+			await withStatus('Opening saved search', openSearch(ss.Search));
+		}, async function keydown(e) {
+			if (e.key === 'Enter') {
+				e.stopPropagation();
+				await withStatus('Opening saved search', openSearch(ss.Search));
+			}
+		}, dom.div(style({ padding: '.15em .25em', display: 'flex', justifyContent: 'space-between' }), dom.div(ss.Name), dom.div(style({ whiteSpace: 'nowrap' }), dom.clickbutton('x', dom._class('mailboxHoverOnly'), style({ padding: '0 .25em', fontSize: '.8em' }), attr.arialabel('Remove saved search.'), attr.title('Remove saved search.'), async function click(e) {
+			e.stopPropagation();
+			if (!window.confirm('Are you sure you want to remove saved search "' + ss.Name + '"?')) {
+				return;
+			}
+			await withStatus('Removing saved search', client.SavedSearchRemove(ss.ID));
+			savedSearches = savedSearches.filter(xss => xss.ID !== ss.ID);
+			renderSavedSearches();
+		}), ' ', dom.b(dom._class('silenttitle'), ss.Unseen === 0 ? [] : ['' + ss.Unseen, attr.title('' + ss.Unseen + ' unread')])))))));
+	};
 	dom._kids(root, dom.div(attr.role('region'), attr.arialabel('Mailboxes'), dom.div(dom.h1('Mailboxes', css('mailboxesTitle', { display: 'inline', fontSize: 'inherit' })), ' ', dom.clickbutton('...', attr.arialabel('Mailboxes actions'), attr.title('Actions on mailboxes like creating a new mailbox or exporting all email.'), function click(e) {
 		e.stopPropagation();
 		const remove = popover(e.target, { transparent: true }, dom.div(css('mailboxesActions', { display: 'flex', flexDirection: 'column', gap: '.5ex' }), dom.div(dom.clickbutton('Create mailbox', attr.arialabel('Create new mailbox.'), attr.title('Create new mailbox.'), style({ padding: '0 .25em' }), function click(e) {
@@ -5855,7 +7452,7 @@ const newMailboxlistView = (msglistView, requestNewView, updatePageTitle, setLoc
 			popoverExport(ref, '', null);
 			remove();
 		}))));
-	})), mailboxesElem));
+	})), mailboxesElem), savedSearchesElem);
 	const loadMailboxes = (mailboxes, mbnameOpt) => {
 		mailboxViews = mailboxes.map(mb => newMailboxView(mb, mblv, otherMailbox));
 		updateMailboxNames();
@@ -5971,6 +7568,15 @@ const newMailboxlistView = (msglistView, requestNewView, updatePageTitle, setLoc
 			}
 			mbv.setKeywords(keywords);
 		},
+		setSavedSearches: (l) => {
+			savedSearches = l;
+			renderSavedSearches();
+		},
+		addSavedSearch: (ss) => {
+			savedSearches = [...savedSearches.filter(xss => xss.ID !== ss.ID), ss];
+			savedSearches.sort((a, b) => a.Name < b.Name ? -1 : 1);
+			renderSavedSearches();
+		},
 	};
 	return mblv;
 };
@@ -6263,7 +7869,16 @@ const newSearchView = (searchbarElem, mailboxlistView, startSearch, searchViewCl
 			},
 		};
 		return v;
-	}), () => ' '), ' ', labels = dom.input(focusPlaceholder('todo -done "-dashingname"'), attr.title('User-defined labels.'), changeHandlers))), dom.tr(dom.td('Headers'), headersCell = dom.td(headerViews = [newHeaderView(true)])), dom.tr(dom.td('Size between'), dom.td(minsize = dom.input(style({ width: '6em' }), focusPlaceholder('10kb'), changeHandlers), ' and ', maxsize = dom.input(style({ width: '6em' }), focusPlaceholder('1mb'), changeHandlers)))), dom.div(style({ padding: '1ex', textAlign: 'right' }), dom.submitbutton('Search')), async function submit(e) {
+	}), () => ' '), ' ', labels = dom.input(focusPlaceholder('todo -done "-dashingname"'), attr.title('User-defined labels.'), changeHandlers))), dom.tr(dom.td('Headers'), headersCell = dom.td(headerViews = [newHeaderView(true)])), dom.tr(dom.td('Size between'), dom.td(minsize = dom.input(style({ width: '6em' }), focusPlaceholder('10kb'), changeHandlers), ' and ', maxsize = dom.input(style({ width: '6em' }), focusPlaceholder('1mb'), changeHandlers)))), dom.div(style({ padding: '1ex', textAlign: 'right' }), dom.clickbutton('Save search', attr.title('Save the search, showing it with the mailboxes, with a count of matching messages.'), async function click(e) {
+		const name = window.prompt('Name for the saved search');
+		if (!name) {
+			return;
+		}
+		const [pf, pnotf, _] = parseSearch(searchbarElem.value, mailboxlistView);
+		const [f, notf] = await hashFilters(pf, pnotf);
+		const ss = await withStatus('Saving search', client.SavedSearchSave({ ID: 0, Name: name, Search: searchbarElem.value, Filter: f, NotFilter: notf, Total: 0, Unseen: 0 }), e.target);
+		mailboxlistView.addSavedSearch(ss);
+	}), ' ', dom.submitbutton('Search')), async function submit(e) {
 		e.preventDefault();
 		await searchView.submit();
 	})));
@@ -6318,8 +7933,11 @@ const parseComposeMailto = (mailto) => {
 };
 const init = async () => {
 	let connectionElem; // SSE connection status/error. Empty when connected.
+	let quotaElem; // Warning when near quota or over soft limit.
+	let impersonationElem; // Banner while an admin is acting as the account.
 	let layoutElem; // Select dropdown for layout.
 	let accountElem;
+	let sharedElem; // Button to open mailboxes of other accounts, if any gave access.
 	let loginAddressElem;
 	let msglistscrollElem;
 	let queryactivityElem; // We show ... when a query is active and data is forthcoming.
@@ -6337,6 +7955,7 @@ const init = async () => {
 	let requestSequence = 0; // Counter for assigning requestID.
 	let requestID = 0; // Current request, server will mirror it in SSE data. If we get data for a different id, we ignore it.
 	let requestAnchorMessageID = 0; // For pagination.
+	let requestLastReceived = null; // Of last message in view, for resuming the view after reconnect.
 	let requestViewEnd = false; // If true, there is no more data to fetch, no more page needed for this view.
 	let requestFilter = newFilter();
 	let requestNotFilter = newNotFilter();
@@ -6347,10 +7966,10 @@ const init = async () => {
 		const mb = mailboxlistView && mailboxlistView.activeMailbox();
 		const addr = loginAddress ? loginAddress.User + '@' + formatDomain(loginAddress.Domain) : '';
 		if (!mb) {
-			document.title = [addr, 'Mox Webmail'].join(' - ');
+			document.title = [addr, branding.Name || 'Mox Webmail'].join(' - ');
 		}
 		else {
-			document.title = ['(' + mb.Unread + ') ' + mb.Name, addr, 'Mox Webmail'].join(' - ');
+			document.title = ['(' + mb.Unread + ') ' + mb.Name, addr, branding.Name || 'Mox Webmail'].join(' - ');
 		}
 	};
 	const setLocationHash = () => {
@@ -6409,6 +8028,7 @@ const init = async () => {
 			requestNotFilter = notFilterOpt || newNotFilter();
 		}
 		requestAnchorMessageID = 0;
+		requestLastReceived = null;
 		requestViewEnd = false;
 		const bounds = msglistscrollElem.getBoundingClientRect();
 		await requestMessages(bounds, requestMsgID);
@@ -6422,7 +8042,7 @@ const init = async () => {
 		};
 		requestSequence++;
 		requestID = requestSequence;
-		const [f, notf] = refineFilters(requestFilter, requestNotFilter);
+		const [f, notf] = await hashFilters(...refineFilters(requestFilter, requestNotFilter));
 		const query = {
 			OrderAsc: settings.orderAsc,
 			Threading: settings.threading,
@@ -6443,23 +8063,28 @@ const init = async () => {
 	};
 	// msgElem can show a message, show actions on multiple messages, or be empty.
 	let msgElem = dom.div(css('msgElem', { position: 'absolute', right: 0, left: 0, top: 0, bottom: 0, backgroundColor: styles.backgroundColorMild }));
-	// Returns possible labels based, either from active mailbox (possibly from search), or all mailboxes.
+	// Returns possible labels: the configured labels in their order, followed by
+	// keywords from the active mailbox (possibly from search), or all mailboxes.
 	const possibleLabels = () => {
-		if (requestFilter.MailboxID > 0) {
-			const mb = mailboxlistView.findMailboxByID(requestFilter.MailboxID);
-			if (mb) {
-				return mb.Keywords || [];
-			}
-		}
-		const all = {};
-		mailboxlistView.mailboxes().forEach(mb => {
-			for (const k of (mb.Keywords || [])) {
-				all[k] = undefined;
+		const configured = (accountSettings?.Labels || []).map(l => l.Name);
+		const inUse = () => {
+			if (requestFilter.MailboxID > 0) {
+				const mb = mailboxlistView.findMailboxByID(requestFilter.MailboxID);
+				if (mb) {
+					return mb.Keywords || [];
+				}
 			}
-		});
-		const l = Object.keys(all);
-		l.sort();
-		return l;
+			const all = {};
+			mailboxlistView.mailboxes().forEach(mb => {
+				for (const k of (mb.Keywords || [])) {
+					all[k] = undefined;
+				}
+			});
+			const l = Object.keys(all);
+			l.sort();
+			return l;
+		};
+		return [...configured, ...inUse().filter(kw => !configured.includes(kw))];
 	};
 	const refineKeyword = async (kw) => {
 		settingsPut({ ...settings, refine: 'label:' + kw });
@@ -6481,7 +8106,13 @@ const init = async () => {
 	const listMailboxes = () => mailboxlistView.mailboxes();
 	const activeMailbox = () => mailboxlistView.activeMailbox();
 	const msglistView = newMsglistView(msgElem, activeMailbox, listMailboxes, setLocationHash, otherMailbox, possibleLabels, () => msglistscrollElem ? msglistscrollElem.getBoundingClientRect().height : 0, refineKeyword, viewportEnsureMessages);
-	const mailboxlistView = newMailboxlistView(msglistView, requestNewView, updatePageTitle, setLocationHash, unloadSearch, otherMailbox);
+	// Start a search, for opening a saved search.
+	const openSearch = async (q) => {
+		searchbarElem.value = q;
+		searchbarElemBox.style.flexGrow = '4';
+		await searchView.submit();
+	};
+	const mailboxlistView = newMailboxlistView(msglistView, requestNewView, updatePageTitle, setLocationHash, unloadSearch, otherMailbox, openSearch);
 	let refineUnreadBtn, refineReadBtn, refineAttachmentsBtn, refineLabelBtn;
 	const refineToggleActive = (btn) => {
 		for (const e of [refineUnreadBtn, refineReadBtn, refineAttachmentsBtn, refineLabelBtn]) {
@@ -6515,7 +8146,7 @@ const init = async () => {
 				await withStatus('Requesting messages', requestNewView(false));
 				remove();
 			};
-			return dom.div(dom.clickbutton(styleClasses.keyword, keywordButtonStyle, l, async function click() {
+			return dom.div(dom.clickbutton(styleClasses.keyword, keywordButtonStyle, keywordColor(l), l, async function click() {
 				await selectLabel();
 			}));
 		}), labels.length === 0 ? dom.div('No labels yet, set one on a message first.') : []));
@@ -6523,7 +8154,9 @@ const init = async () => {
 		settingsPut({ ...settings, refine: '' });
 		refineToggleActive(e.target);
 		await withStatus('Requesting messages', requestNewView(false));
-	})), dom.div(queryactivityElem = dom.span(), ' ', threadMode = dom.select(attr.arialabel('Thread modes.'), attr.title('Off: Threading disabled, messages are shown individually.\nOn: Group messages in threads, expanded by default except when (previously) manually collapsed.\nUnread: Only expand thread with unread messages, ignoring and not saving whether they were manually collapsed.'), dom.option('Threads: Off', attr.value(api.ThreadMode.ThreadOff), settings.threading === api.ThreadMode.ThreadOff ? attr.selected('') : []), dom.option('Threads: On', attr.value(api.ThreadMode.ThreadOn), settings.threading === api.ThreadMode.ThreadOn ? attr.selected('') : []), dom.option('Threads: Unread', attr.value(api.ThreadMode.ThreadUnread), settings.threading === api.ThreadMode.ThreadUnread ? attr.selected('') : []), async function change() {
+	})), dom.div(queryactivityElem = dom.span(), ' ', dom.clickbutton('All...', attr.title('Apply an action to all messages matching the current mailbox or search, including those not loaded in the list.'), function click() {
+		shortcutCmd(cmdBulk, shortcuts);
+	}), ' ', threadMode = dom.select(attr.arialabel('Thread modes.'), attr.title('Off: Threading disabled, messages are shown individually.\nOn: Group messages in threads, expanded by default except when (previously) manually collapsed.\nUnread: Only expand thread with unread messages, ignoring and not saving whether they were manually collapsed.'), dom.option('Threads: Off', attr.value(api.ThreadMode.ThreadOff), settings.threading === api.ThreadMode.ThreadOff ? attr.selected('') : []), dom.option('Threads: On', attr.value(api.ThreadMode.ThreadOn), settings.threading === api.ThreadMode.ThreadOn ? attr.selected('') : []), dom.option('Threads: Unread', attr.value(api.ThreadMode.ThreadUnread), settings.threading === api.ThreadMode.ThreadUnread ? attr.selected('') : []), async function change() {
 		let reset = settings.threading === api.ThreadMode.ThreadOff;
 		settingsPut({ ...settings, threading: threadMode.value });
 		reset = reset || settings.threading === api.ThreadMode.ThreadOff;
@@ -6638,11 +8271,12 @@ const init = async () => {
 	};
 	const cmdCompose = async () => {
 		let body = '';
-		let sig = accountSettings?.Signature || '';
+		const ident = findIdentity();
+		let sig = identitySignature(ident);
 		if (sig) {
 			body += '\n\n' + sig;
 		}
-		compose({ body: body, editOffset: 0 }, listMailboxes);
+		compose({ body: body, editOffset: 0, identity: ident, replyto: ident?.ReplyTo }, listMailboxes);
 	};
 	const cmdOpenInbox = async () => {
 		const mb = mailboxlistView.findMailboxByName('Inbox');
@@ -6659,6 +8293,50 @@ const init = async () => {
 			btn.focus();
 		}
 	};
+	// Shown in the status while a bulk action is in progress, updated through SSE events.
+	let bulkProgressElem;
+	// Apply an action to all messages matching the current mailbox or search, also
+	// messages not loaded in the message list. The server does the work.
+	const cmdBulk = async () => {
+		if (!sseID) {
+			return;
+		}
+		const [f, notf] = await hashFilters(...refineFilters(requestFilter, requestNotFilter));
+		const query = { OrderAsc: settings.orderAsc, Threading: api.ThreadMode.ThreadOff, Filter: f, NotFilter: notf };
+		let fieldset;
+		let label;
+		let mailbox;
+		const apply = async (action, what) => {
+			try {
+				await withStatus(what, client.MessageBulk(sseID, query, action), fieldset);
+			}
+			finally {
+				if (bulkProgressElem) {
+					bulkProgressElem.remove();
+					bulkProgressElem = undefined;
+				}
+			}
+			remove();
+		};
+		const remove = popup(style({ maxWidth: '40em' }), dom.h1('Apply to all matching messages'), dom.p('Apply an action to all messages in the current mailbox or search, including messages not yet shown in the message list.'), fieldset = dom.fieldset(dom.div(dom.clickbutton('Mark read', async function click() {
+			await apply({ Op: api.BulkOp.BulkMarkRead, MailboxID: 0, Label: '' }, 'Marking messages as read');
+		}), ' ', dom.clickbutton('Mark unread', async function click() {
+			await apply({ Op: api.BulkOp.BulkMarkUnread, MailboxID: 0, Label: '' }, 'Marking messages as unread');
+		}), ' ', dom.clickbutton('Delete permanently', async function click() {
+			if (!window.confirm('Are you sure you want to permanently delete all matching messages?')) {
+				return;
+			}
+			await apply({ Op: api.BulkOp.BulkDelete, MailboxID: 0, Label: '' }, 'Deleting messages');
+		})), dom.form(style({ marginTop: '1ex' }), async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			await apply({ Op: api.BulkOp.BulkLabel, MailboxID: 0, Label: label.value }, 'Adding label to messages');
+		}, dom.label('Label ', label = dom.input(attr.required(''))), ' ', dom.submitbutton('Add label')), dom.form(style({ marginTop: '1ex' }), async function submit(e) {
+			e.preventDefault();
+			e.stopPropagation();
+			await apply({ Op: api.BulkOp.BulkMove, MailboxID: parseInt(mailbox.value), Label: '' }, 'Moving messages');
+		}, dom.label('Mailbox ', mailbox = dom.select(listMailboxes().map(mb => dom.option(mb.Name, attr.value('' + mb.ID))))), ' ', dom.submitbutton('Move'))));
+	};
 	const shortcuts = {
 		i: cmdOpenInbox,
 		'/': cmdSearch,
@@ -6667,6 +8345,7 @@ const init = async () => {
 		c: cmdCompose,
 		'ctrl m': cmdFocusMsg,
 		'ctrl !': cmdSettings,
+		B: cmdBulk,
 	};
 	const topMailboxesStyle = css('topMailboxes', { backgroundColor: styles.mailboxesTopBackgroundColor });
 	css('searchbarActive', { background: styles.mailboxActiveBackground }); // class set on searchbarElem when active.
@@ -6717,7 +8396,7 @@ const init = async () => {
 	}), async function submit(e) {
 		e.preventDefault();
 		await searchView.submit();
-	})), connectionElem = dom.div(), statusElem = dom.div(css('status', { marginLeft: '.5em', flexGrow: '1' }), attr.role('status')), dom.div(style({ paddingLeft: '1em' }), layoutElem = dom.select(attr.title('Layout of message list and message panes. Top/bottom has message list above message view. Left/Right has message list left, message view right. Auto selects based on window width and automatically switches on resize. Wide screens get left/right, smaller screens get top/bottom.'), dom.option('Auto layout', attr.value('auto'), settings.layout === 'auto' ? attr.selected('') : []), dom.option('Top/bottom', attr.value('topbottom'), settings.layout === 'topbottom' ? attr.selected('') : []), dom.option('Left/right', attr.value('leftright'), settings.layout === 'leftright' ? attr.selected('') : []), function change() {
+	})), connectionElem = dom.div(), quotaElem = dom.div(), impersonationElem = dom.div(), statusElem = dom.div(css('status', { marginLeft: '.5em', flexGrow: '1' }), attr.role('status')), dom.div(style({ paddingLeft: '1em' }), layoutElem = dom.select(attr.title('Layout of message list and message panes. Top/bottom has message list above message view. Left/Right has message list left, message view right. Auto selects based on window width and automatically switches on resize. Wide screens get left/right, smaller screens get top/bottom.'), dom.option('Auto layout', attr.value('auto'), settings.layout === 'auto' ? attr.selected('') : []), dom.option('Top/bottom', attr.value('topbottom'), settings.layout === 'topbottom' ? attr.selected('') : []), dom.option('Left/right', attr.value('leftright'), settings.layout === 'leftright' ? attr.selected('') : []), function change() {
 		settingsPut({ ...settings, layout: layoutElem.value });
 		if (layoutElem.value === 'auto') {
 			autoselectLayout();
@@ -6725,7 +8404,7 @@ const init = async () => {
 		else {
 			selectLayout(layoutElem.value);
 		}
-	}), ' ', dom.clickbutton('Tooltip', attr.title('Show tooltips, based on the title attributes (underdotted text) for the focused element and all user interface elements below it. Use the keyboard shortcut "ctrl ?" instead of clicking on the tooltip button, which changes focus to the tooltip button.'), clickCmd(cmdTooltip, shortcuts)), ' ', dom.clickbutton('Help', attr.title('Show popup with basic usage information and a keyboard shortcuts.'), clickCmd(cmdHelp, shortcuts)), ' ', dom.clickbutton('Settings', attr.title('Change settings for composing messages.'), clickCmd(cmdSettings, shortcuts)), ' ', accountElem = dom.span(), ' ', loginAddressElem = dom.span(), ' ', dom.clickbutton('Logout', attr.title('Logout, invalidating this session.'), async function click(e) {
+	}), ' ', dom.clickbutton('Tooltip', attr.title('Show tooltips, based on the title attributes (underdotted text) for the focused element and all user interface elements below it. Use the keyboard shortcut "ctrl ?" instead of clicking on the tooltip button, which changes focus to the tooltip button.'), clickCmd(cmdTooltip, shortcuts)), ' ', dom.clickbutton('Help', attr.title('Show popup with basic usage information and a keyboard shortcuts.'), clickCmd(cmdHelp, shortcuts)), ' ', dom.clickbutton('Settings', attr.title('Change settings for composing messages.'), clickCmd(cmdSettings, shortcuts)), ' ', sharedElem = dom.span(), accountElem = dom.span(), ' ', loginAddressElem = dom.span(), ' ', dom.clickbutton('Logout', attr.title('Logout, invalidating this session.'), async function click(e) {
 		await withStatus('Logging out', client.Logout(), e.target);
 		localStorageRemove('webmailcsrftoken');
 		if (eventSource) {
@@ -6956,6 +8635,7 @@ const init = async () => {
 		await withStatus('Requesting messages', requestNewView(false, f, notf));
 	});
 	let eventSource = null; // If set, we have a connection.
+	let lastEventID = 0; // From last SSE event with an ID, for resuming the view after reconnect.
 	let connecting = false; // Check before reconnecting.
 	let noreconnect = false; // Set after one reconnect attempt fails.
 	let noreconnectTimer = 0; // Timer ID for resetting noreconnect.
@@ -7001,6 +8681,8 @@ const init = async () => {
 	// Set to compose options when we were opened with a mailto URL. We open the
 	// compose window after we received the "start" message with our addresses.
 	let openComposeOptions;
+	// Autosaved drafts are checked once, on the first "start" message.
+	let autosavedDraftsChecked = false;
 	const connect = async (isreconnect) => {
 		connectionElem.classList.toggle('loading', true);
 		dom._kids(connectionElem);
@@ -7037,7 +8719,7 @@ const init = async () => {
 		if (searchQuery) {
 			loadSearch(searchQuery);
 		}
-		[f, notf] = refineFilters(requestFilter, requestNotFilter);
+		[f, notf] = await hashFilters(...refineFilters(requestFilter, requestNotFilter));
 		const fetchCount = Math.max(50, 3 * Math.ceil(msglistscrollElem.getBoundingClientRect().height / msglistView.itemHeight()));
 		const query = {
 			OrderAsc: settings.orderAsc,
@@ -7050,14 +8732,30 @@ const init = async () => {
 			Count: fetchCount,
 			DestMessageID: msgid,
 		};
-		viewSequence++;
-		viewID = viewSequence;
+		// After a reconnect, we try to resume the current view, getting only the changes
+		// since the last event we received instead of reloading all messages. If the
+		// server cannot resume, it starts the view from our request, and we clear the
+		// list when we get the start event.
+		let resume = '';
+		if (isreconnect && lastEventID && viewID && !requestID && requestLastReceived) {
+			const viewResume = {
+				LastEventID: lastEventID,
+				LastMessageReceived: requestLastReceived,
+				ViewEnd: requestViewEnd,
+			};
+			resume = '&resume=' + encodeURIComponent(JSON.stringify(viewResume));
+		}
+		else {
+			viewSequence++;
+			viewID = viewSequence;
+			requestAnchorMessageID = 0;
+			requestLastReceived = null;
+			requestViewEnd = false;
+			clearList();
+		}
 		// We get an implicit query for the automatically selected mailbox or query.
 		requestSequence++;
 		requestID = requestSequence;
-		requestAnchorMessageID = 0;
-		requestViewEnd = false;
-		clearList();
 		const request = {
 			ID: requestID,
 			// A new SSEID is created by the server, sent in the initial response message.
@@ -7073,7 +8771,7 @@ const init = async () => {
 			}
 		}
 		catch (err) { }
-		eventSource = new window.EventSource('events?singleUseToken=' + encodeURIComponent(token) + '&request=' + encodeURIComponent(JSON.stringify(request)) + slow);
+		eventSource = new window.EventSource('events?singleUseToken=' + encodeURIComponent(token) + '&request=' + encodeURIComponent(JSON.stringify(request)) + resume + slow);
 		let eventID = window.setTimeout(() => dom._kids(statusElem, 'Connecting... '), 1000);
 		eventSource.addEventListener('open', (e) => {
 			log('eventsource open', { e });
@@ -7100,7 +8798,7 @@ const init = async () => {
 				window.clearTimeout(eventID);
 				eventID = 0;
 			}
-			document.title = ['(not connected)', loginAddress ? (loginAddress.User + '@' + formatDomain(loginAddress.Domain)) : '', 'Mox Webmail'].filter(s => s).join(' - ');
+			document.title = ['(not connected)', loginAddress ? (loginAddress.User + '@' + formatDomain(loginAddress.Domain)) : '', branding.Name || 'Mox Webmail'].filter(s => s).join(' - ');
 			dom._kids(connectionElem);
 			if (noreconnect) {
 				dom._kids(statusElem, capitalizeFirst(errmsg) + ', not automatically retrying. ');
@@ -7122,6 +8820,13 @@ const init = async () => {
 			const errmsg = JSON.parse(e.data) || '(no error message)';
 			sseError('Server error: "' + errmsg + '"');
 		});
+		// Events that change the view carry an ID, which we send when resuming after a
+		// reconnect.
+		const trackEventID = (e) => {
+			if (e.lastEventId) {
+				lastEventID = parseInt(e.lastEventId);
+			}
+		};
 		const checkParse = (fn) => {
 			try {
 				return fn();
@@ -7142,11 +8847,15 @@ const init = async () => {
 			lastServerVersion = data.Version;
 			const start = checkParse(() => api.parser.EventStart(data));
 			log('event start', start);
+			trackEventID(e);
 			accountSettings = start.Settings;
 			connecting = false;
 			sseID = start.SSEID;
 			loginAddress = start.LoginAddress;
 			dom._kids(accountElem, start.AccountPath ? dom.a(attr.href(start.AccountPath), 'Account') : []);
+			dom._kids(quotaElem, start.QuotaWarning ? dom.span(css('quotaWarning', { backgroundColor: styles.warningBackgroundColor, padding: '0 .15em', marginLeft: '.5em' }), start.QuotaWarning) : []);
+			impersonationReadOnly = !!start.Impersonation?.ReadOnly;
+			dom._kids(impersonationElem, start.Impersonation ? dom.span(css('impersonation', { backgroundColor: styles.warningBackgroundColor, padding: '0 .15em', marginLeft: '.5em', fontWeight: 'bold' }), 'Impersonated by ' + start.Impersonation.By + (start.Impersonation.ReadOnly ? ', read-only' : ', full access') + ', until ' + start.Impersonation.End.toLocaleString()) : []);
 			const loginAddr = formatEmail(loginAddress);
 			dom._kids(loginAddressElem, loginAddr);
 			accountAddresses = start.Addresses || [];
@@ -7163,8 +8872,27 @@ const init = async () => {
 				return a.User < b.User ? -1 : 1;
 			});
 			domainAddressConfigs = start.DomainAddressConfigs || {};
+			accountIdentities = start.Identities || [];
+			pgpAddresses = (start.PGPAddresses || []).map(s => s.toLowerCase());
+			searchIndexSalt = start.SearchIndexSalt;
+			try {
+				window.localStorage.setItem('webmailsearchindexsalt', searchIndexSalt);
+			}
+			catch (err) {
+			}
+			accountDelegated = start.Delegated || [];
+			dom._kids(sharedElem, accountDelegated.length === 0 ? [] : [dom.clickbutton('Shared', attr.title('Open mailboxes of other accounts that gave access to this account.'), async function click() { await cmdDelegations(); }), ' ']);
 			rejectsMailbox = start.RejectsMailbox;
-			clearList();
+			// If our view was resumed, we keep the messages we have, and only get changes.
+			if (start.Resumed) {
+				requestID = 0;
+			}
+			else {
+				requestAnchorMessageID = 0;
+				requestLastReceived = null;
+				requestViewEnd = false;
+				clearList();
+			}
 			// If we were opened through a mailto: link, it's time to open the compose window.
 			if (openComposeOptions) {
 				(async () => {
@@ -7176,6 +8904,16 @@ const init = async () => {
 					openComposeOptions = undefined;
 				})();
 			}
+			if (!autosavedDraftsChecked) {
+				autosavedDraftsChecked = true;
+				client.Drafts().then(drafts => {
+					if (drafts && drafts.length > 0 && !composeView) {
+						autosavedDraftsPopup(drafts, listMailboxes);
+					}
+				}, (err) => {
+					log('listing autosaved drafts', err);
+				});
+			}
 			let mailboxName = start.MailboxName;
 			let mb = (start.Mailboxes || []).find(mb => mb.Name === start.MailboxName);
 			if (mb) {
@@ -7185,14 +8923,17 @@ const init = async () => {
 				mailboxName = (start.Mailboxes || []).find(mb => mb.ID === requestFilter.MailboxID)?.Name || '';
 			}
 			mailboxlistView.loadMailboxes(start.Mailboxes || [], search.active ? undefined : mailboxName);
+			mailboxlistView.setSavedSearches(start.SavedSearches || []);
 			if (searchView.root.parentElement) {
 				searchView.ensureLoaded();
 			}
 			if (!mb) {
 				updatePageTitle();
 			}
-			dom._kids(queryactivityElem, 'loading...');
-			msglistscrollElem.appendChild(listloadingElem);
+			if (!start.Resumed) {
+				dom._kids(queryactivityElem, 'loading...');
+				msglistscrollElem.appendChild(listloadingElem);
+			}
 			// We'll clear noreconnect when we've held a connection for 5 seconds. Firefox
 			// disconnects often, on any network change including with docker container starts,
 			// such as for integration tests.
@@ -7201,6 +8942,20 @@ const init = async () => {
 				noreconnectTimer = 0;
 			}, 5 * 1000);
 		});
+		eventSource.addEventListener('savedSearches', (e) => {
+			const savedSearches = checkParse(() => api.parser.EventSavedSearches(JSON.parse(e.data)));
+			log('event savedSearches', savedSearches);
+			mailboxlistView.setSavedSearches(savedSearches.SavedSearches || []);
+		});
+		eventSource.addEventListener('bulkProgress', (e) => {
+			const bulkProgress = checkParse(() => api.parser.EventBulkProgress(JSON.parse(e.data)));
+			log('event bulkProgress', bulkProgress);
+			if (!bulkProgressElem) {
+				bulkProgressElem = dom.span();
+				statusElem.appendChild(bulkProgressElem);
+			}
+			dom._kids(bulkProgressElem, 'Processed ' + bulkProgress.Done + ' of ' + bulkProgress.Total + ' messages... ');
+		});
 		eventSource.addEventListener('viewErr', async (e) => {
 			const viewErr = checkParse(() => api.parser.EventViewErr(JSON.parse(e.data)));
 			log('event viewErr', viewErr);
@@ -7220,6 +8975,7 @@ const init = async () => {
 		eventSource.addEventListener('viewReset', async (e) => {
 			const viewReset = checkParse(() => api.parser.EventViewReset(JSON.parse(e.data)));
 			log('event viewReset', viewReset);
+			trackEventID(e);
 			if (viewReset.ViewID !== viewID || viewReset.RequestID !== requestID) {
 				log('received viewReset for other viewID or requestID', { expected: { viewID, requestID }, got: { viewID: viewReset.ViewID, requestID: viewReset.RequestID } });
 				return;
@@ -7232,6 +8988,7 @@ const init = async () => {
 		eventSource.addEventListener('viewMsgs', async (e) => {
 			const viewMsgs = checkParse(() => api.parser.EventViewMsgs(JSON.parse(e.data)));
 			log('event viewMsgs', viewMsgs);
+			trackEventID(e);
 			if (viewMsgs.ViewID !== viewID || viewMsgs.RequestID !== requestID) {
 				log('received viewMsgs for other viewID or requestID', { expected: { viewID, requestID }, got: { viewID: viewMsgs.ViewID, requestID: viewMsgs.RequestID } });
 				return;
@@ -7249,7 +9006,9 @@ const init = async () => {
 				}
 			}
 			if (viewMsgs.MessageItems && viewMsgs.MessageItems.length > 0) {
-				requestAnchorMessageID = viewMsgs.MessageItems[viewMsgs.MessageItems.length - 1][0].Message.ID;
+				const lastmi = viewMsgs.MessageItems[viewMsgs.MessageItems.length - 1][0];
+				requestAnchorMessageID = lastmi.Message.ID;
+				requestLastReceived = lastmi.Message.Received;
 			}
 			requestViewEnd = viewMsgs.ViewEnd;
 			if (requestViewEnd) {
@@ -7268,7 +9027,9 @@ const init = async () => {
 		eventSource.addEventListener('viewChanges', async (e) => {
 			const viewChanges = checkParse(() => api.parser.EventViewChanges(JSON.parse(e.data)));
 			log('event viewChanges', viewChanges);
-			if (viewChanges.ViewID !== viewID) {
+			trackEventID(e);
+			// ViewID 0 is for changes to mailboxes and threads of the account, for any view.
+			if (viewChanges.ViewID !== 0 && viewChanges.ViewID !== viewID) {
 				log('received viewChanges for other viewID', { expected: viewID, got: viewChanges.ViewID });
 				return;
 			}
@@ -130,6 +130,9 @@ declare let page: HTMLElement
 // From customization script.
 declare let moxBeforeDisplay: (root: HTMLElement) => void
 
+// Per-domain customization, from branding.js.
+const branding: {Name?: string, LoginText?: string, Logo?: boolean} = (window as any).moxBranding || {}
+
 let moxversion: string
 let moxgoos: string
 let moxgoarch: string
@@ -144,6 +147,12 @@ try {
 
 let accountSettings: api.Settings
 
+// Style for showing keyword kw, with the color of its label, if configured.
+const keywordColor = (kw: string) => {
+	const l = (accountSettings?.Labels || []).find(l => l.Name === kw)
+	return l && l.Color ? style({backgroundColor: l.Color}) : []
+}
+
 const defaultSettings = {
 	mailboxesWidth: 240,
 	layout: 'auto', // Automatic switching between left/right and top/bottom layout, based on screen width.
@@ -242,9 +251,34 @@ let loginAddress: api.MessageAddress | null = null
 // the account has an address for.
 let domainAddressConfigs: {[domainASCII: string]: api.DomainAddressConfig} = {}
 
+// Identities of the account, with From address, display name and signature. Listed
+// first as From address when composing. Set when SSE connection is initialized.
+let accountIdentities: api.Identity[] = []
+
+// Addresses with an OpenPGP private key in the account, offered for signing and
+// encrypting when composing. Set when SSE connection is initialized.
+let pgpAddresses: string[] = []
+
+// Base64-encoded salt for hashing search words, for accounts with a hashed
+// full-text search index, see api.EventStart. Remembered for the request made
+// when connecting, before the start event.
+let searchIndexSalt = ''
+try {
+	searchIndexSalt = window.localStorage.getItem('webmailsearchindexsalt') || ''
+} catch (err) {
+}
+
+// Access to mailboxes given to this account by other accounts, for opening their
+// mailboxes and sending messages. Set when SSE connection is initialized.
+let accountDelegated: api.DelegatedAccess[] = []
+
 // Mailbox containing rejects.
 let rejectsMailbox: string = ''
 
+// Whether an admin is acting as the account in a read-only session. Messages are
+// not marked as read automatically.
+let impersonationReadOnly = false
+
 // Last known server version. For asking to reload.
 let lastServerVersion: string = ''
 
@@ -257,6 +291,22 @@ const login = async (reason: string) => {
 		let autosize: HTMLElement
 		let username: HTMLInputElement
 		let password: HTMLInputElement
+		let totpLabel: HTMLElement
+		let totp: HTMLInputElement
+		let sso: HTMLElement
+		const loggedIn = (token: string) => {
+			try {
+				window.localStorage.setItem('webmailcsrftoken', token)
+			} catch (err) {
+				console.log('saving csrf token in localStorage', err)
+			}
+			root.remove()
+			if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
+				origFocus.focus()
+			}
+			popupOpen = false
+			resolve(token)
+		}
 		const root = dom.div(
 			css('loginOverlay', {position: 'absolute', top: 0, right: 0, bottom: 0, left: 0, backgroundColor: styles.overlayOpaqueBackgroundColor, display: 'flex', alignItems: 'center', justifyContent: 'center', zIndex: zindexes.login, animation: 'fadein .15s ease-in'}),
 			dom.div(
@@ -286,27 +336,27 @@ const login = async (reason: string) => {
 							try {
 								fieldset.disabled = true
 								const loginToken = await client.LoginPrep()
-								const token = await client.Login(loginToken, username.value, password.value)
-								try {
-									window.localStorage.setItem('webmailcsrftoken', token)
-								} catch (err) {
-									console.log('saving csrf token in localStorage', err)
-								}
-								root.remove()
-								if (origFocus && origFocus instanceof HTMLElement && origFocus.parentNode) {
-									origFocus.focus()
-								}
-								popupOpen = false
-								resolve(token)
+								const token = await client.Login(loginToken, username.value, password.value, totp.value)
+								loggedIn(token)
 							} catch (err) {
 								console.log('login error', err)
+								if ((err as any).code === 'user:totpRequired') {
+									// Password is valid, ask for the code from the authenticator app.
+									totpLabel.style.display = 'block'
+									totp.required = true
+									fieldset.disabled = false
+									totp.focus()
+									return
+								}
 								window.alert('Error: ' + errmsg(err))
 							} finally {
 								fieldset.disabled = false
 							}
 						},
 						fieldset=dom.fieldset(
-							dom.h1('Mail'),
+							dom.h1(branding.Name || 'Mail'),
+							branding.Logo ? dom.div(style({textAlign: 'center', marginBottom: '2ex'}), dom.img(attr.src('branding/logo'), style({maxWidth: '20em', maxHeight: '6em'}))) : [],
+							branding.LoginText ? dom.div(style({marginBottom: '2ex', maxWidth: '30em', whiteSpace: 'pre-wrap'}), branding.LoginText) : [],
 							dom.label(
 								style({display: 'block', marginBottom: '2ex'}),
 								dom.div('Email address', style({marginBottom: '.5ex'})),
@@ -325,9 +375,34 @@ const login = async (reason: string) => {
 								dom.div('Password', style({marginBottom: '.5ex'})),
 								password=dom.input(attr.type('password'), attr.autocomplete('current-password'), attr.required('')),
 							),
+							totpLabel=dom.label(
+								style({display: 'none', marginBottom: '2ex'}),
+								dom.div('Two-factor authentication code, or recovery code', style({marginBottom: '.5ex'})),
+								totp=dom.input(attr.autocomplete('one-time-code')),
+							),
 							dom.div(
 								style({textAlign: 'center'}),
 								dom.submitbutton('Login'),
+								window.PublicKeyCredential ? [
+									' ',
+									dom.clickbutton('Login with passkey', attr.title('Login with a passkey registered for your account, without password. Or, after entering your email address and password, use the passkey as second factor. Passkeys are added in the account web interface.'), async function click() {
+										reasonElem.remove()
+										try {
+											fieldset.disabled = true
+											const loginToken = await client.LoginPrep()
+											const opts = await client.PasskeyLoginStart(loginToken)
+											const assertion = await passkeyGet(opts)
+											const token = await client.PasskeyLogin(loginToken, password.value ? username.value : '', password.value, assertion)
+											loggedIn(token)
+										} catch (err) {
+											console.log('passkey login error', err)
+											window.alert('Error: ' + errmsg(err))
+										} finally {
+											fieldset.disabled = false
+										}
+									}),
+								] : [],
+								sso=dom.span(),
 							),
 						),
 					)
@@ -336,9 +411,45 @@ const login = async (reason: string) => {
 		)
 		document.body.appendChild(root)
 		username.focus()
+
+		// Offer login through the identity provider, if configured.
+		client.OIDCEnabled().then(enabled => {
+			if (enabled) {
+				dom._kids(sso, ' ', dom.clickbutton('Login with single sign-on', attr.title('Login through the identity provider of your organization.'), function click() {
+					window.location.href = 'oidc/login'
+				}))
+			}
+		}, err => console.log('checking single sign-on', err))
 	})
 }
 
+// The WebAuthn browser API uses ArrayBuffers, the API base64 strings.
+const base64ToBuffer = (s: string | null | undefined) => Uint8Array.from(window.atob(s || ''), c => c.charCodeAt(0)).buffer
+const bufferToBase64 = (buf: ArrayBuffer) => window.btoa(String.fromCharCode(...new Uint8Array(buf)))
+
+// passkeyGet lets the user select a passkey in the browser, returning the signed
+// response for the login.
+const passkeyGet = async (opts: api.PasskeyGetOptions): Promise<api.PasskeyAssertion> => {
+	const cred = await window.navigator.credentials.get({
+		publicKey: {
+			challenge: base64ToBuffer(opts.Challenge),
+			rpId: opts.RPID,
+			timeout: opts.Timeout,
+			userVerification: 'preferred',
+		},
+	}) as PublicKeyCredential | null
+	if (!cred) {
+		throw new Error('no passkey selected')
+	}
+	const resp = cred.response as AuthenticatorAssertionResponse
+	return {
+		CredentialID: bufferToBase64(cred.rawId),
+		ClientDataJSON: bufferToBase64(resp.clientDataJSON),
+		AuthenticatorData: bufferToBase64(resp.authenticatorData),
+		Signature: bufferToBase64(resp.signature),
+	}
+}
+
 const localStorageGet = (k: string): string | null => {
 	try {
 		return window.localStorage.getItem(k)
@@ -370,6 +481,28 @@ const envelopeIdentity = (l: api.MessageAddress[]): api.MessageAddress | null =>
 	return null
 }
 
+// Returns the From header value for composing as identity.
+const identityFrom = (ident: api.Identity) => (ident.DisplayName ? displayName(ident.DisplayName) + ' ' : '') + '<' + ident.Address + '>'
+
+// Returns the signature of the identity, or the account signature without identity.
+const identitySignature = (ident: api.Identity | undefined) => ident ? (ident.Signature || []).join('\n') : (accountSettings?.Signature || '')
+
+// Returns the first identity with an address in l, e.g. the recipients of a
+// message being replied to. Without l, the default identity is returned.
+const findIdentity = (l?: api.MessageAddress[]): api.Identity | undefined => {
+	if (!l) {
+		return accountIdentities.find(ident => ident.Default)
+	}
+	for (const a of l) {
+		const emails = [formatEmail(a), a.User+'@'+a.Domain.ASCII].map(s => s.toLowerCase())
+		const ident = accountIdentities.find(ident => emails.includes(ident.Address.toLowerCase()))
+		if (ident) {
+			return ident
+		}
+	}
+	return undefined
+}
+
 // We can display keyboard shortcuts when a user clicks a button that has a shortcut.
 let shortcutElem = dom.div(css('shortcutFlash', {fontSize: '2em', position: 'absolute', left: '.25em', bottom: '.25em', backgroundColor: '#888', padding: '0.25em .5em', color: 'white', borderRadius: '.15em'}))
 let shortcutTimer = 0
@@ -810,6 +943,40 @@ const refineFilters = (f: api.Filter, notf: api.NotFilter): [api.Filter, api.Not
 	return [f, notf]
 }
 
+// Return filters with the words replaced by hashes of whole words, for accounts
+// with a hashed search index, so search words are not sent to the server. The
+// words are split and hashed like the server does when indexing, see
+// api.EventStart.SearchIndexSalt. The original filters are not modified.
+const hashFilters = async (f: api.Filter, notf: api.NotFilter): Promise<[api.Filter, api.NotFilter]> => {
+	if (!searchIndexSalt || (f.Words || []).length === 0 && (notf.Words || []).length === 0) {
+		return [f, notf]
+	}
+	const salt = Uint8Array.from(atob(searchIndexSalt), c => c.charCodeAt(0))
+	const key = await window.crypto.subtle.importKey('raw', salt, {name: 'HMAC', hash: 'SHA-256'}, false, ['sign'])
+	const hashes = async (words: string[] | null): Promise<string[] | null> => {
+		const l: string[] = []
+		for (const w of words || []) {
+			for (const m of w.toLowerCase().matchAll(/[\p{L}\p{Nd}]+/gu)) {
+				// Long words are indexed as parts of 64 characters, every 32 characters.
+				const chars = Array.from(m[0])
+				for (let i = 0; ; i += 32) {
+					const end = Math.min(i+64, chars.length)
+					const sig = await window.crypto.subtle.sign('HMAC', key, new TextEncoder().encode(chars.slice(i, end).join('')))
+					l.push(Array.from(new Uint8Array(sig).slice(0, 16)).map(b => b.toString(16).padStart(2, '0')).join(''))
+					if (end === chars.length) {
+						break
+					}
+				}
+			}
+		}
+		return l.length > 0 ? l : null
+	}
+	return [
+		{...f, Words: null, WordHashes: await hashes(f.Words)},
+		{...notf, Words: null, WordHashes: await hashes(notf.Words)},
+	]
+}
+
 // For dragging the splitter bars. This function should be called on mousedown. e
 // is the mousedown event. Move is the function to call when the bar was dragged,
 // typically adjusting styling, e.g. absolutely positioned offsets, possibly based
@@ -955,6 +1122,28 @@ const withStatus = async <T>(action: string, promise: Promise<T>, disablable?: D
 	}
 }
 
+// Show a notice with a button to undo sending a message while its delivery is
+// held back.
+const showUndoSend = (result: api.SubmitResult) => {
+	const remove = () => {
+		window.clearTimeout(id)
+		elem.remove()
+	}
+	const id = window.setTimeout(remove, result.UndoUntil!.getTime() - new Date().getTime())
+	const elem = dom.span(
+		'Message will be sent shortly. ',
+		dom.clickbutton('Undo', attr.title('Cancel delivery of the message, and move it to the Drafts mailbox for editing.'), async function click() {
+			remove()
+			await withStatus('Undoing send', client.MessageSubmitUndo(result.QueueMsgIDs || [], result.SentMessageID))
+			const done = dom.span('Sending undone, message moved to Drafts. ')
+			statusElem.appendChild(done)
+			window.setTimeout(() => done.remove(), 5000)
+		}),
+		' ',
+	)
+	statusElem.appendChild(elem)
+}
+
 const withDisabled = async <T>(elem: {disabled: boolean}, p: Promise<T>): Promise<T> => {
 	try {
 		elem.disabled = true
@@ -1100,6 +1289,41 @@ const popup = (...kids: ElemArg[]) => {
 	return close
 }
 
+// Subscribe this browser to Web Push notifications about new messages, shown by
+// the service worker, and register the subscription with the server.
+const webPushSubscribe = async () => {
+	if (!('serviceWorker' in window.navigator) || !('PushManager' in window)) {
+		throw new Error('Notifications are not supported by your browser, or only on HTTPS URLs.')
+	}
+	if (await Notification.requestPermission() !== 'granted') {
+		throw new Error('Permission for showing notifications was not granted.')
+	}
+	const reg = await window.navigator.serviceWorker.register('sw.js')
+	await window.navigator.serviceWorker.ready
+	const key = await client.WebPushKey()
+	const keyBytes = Uint8Array.from(atob(key.replace(/-/g, '+').replace(/_/g, '/')), c => c.charCodeAt(0))
+	let sub = await reg.pushManager.getSubscription()
+	if (sub) {
+		// May have been subscribed with the key of another account.
+		await client.WebPushUnsubscribe(sub.endpoint)
+		await sub.unsubscribe()
+	}
+	sub = await reg.pushManager.subscribe({userVisibleOnly: true, applicationServerKey: keyBytes})
+	const j = sub.toJSON()
+	await client.WebPushSubscribe(sub.endpoint, j.keys?.p256dh || '', j.keys?.auth || '')
+}
+
+// Unsubscribe this browser from Web Push notifications.
+const webPushUnsubscribe = async () => {
+	const reg = await window.navigator.serviceWorker?.getRegistration('sw.js')
+	const sub = await reg?.pushManager.getSubscription()
+	if (!sub) {
+		return
+	}
+	await client.WebPushUnsubscribe(sub.endpoint)
+	await sub.unsubscribe()
+}
+
 // Show settings screen.
 const cmdSettings = async () => {
 	let fieldset: HTMLFieldSetElement
@@ -1107,8 +1331,14 @@ const cmdSettings = async () => {
 	let quoting: HTMLSelectElement
 	let showAddressSecurity: HTMLInputElement
 	let showHTML: HTMLInputElement
+	let remoteContentProxy: HTMLInputElement
 	let showShortcuts: HTMLInputElement
 	let showHeaders: HTMLTextAreaElement
+	let undoSend: HTMLSelectElement
+	let htmlAllowedTags: HTMLInputElement
+	let htmlAllowedAttributes: HTMLInputElement
+	let notifyMailboxes: HTMLTextAreaElement
+	let notifySenders: HTMLInputElement
 
 	if (!accountSettings) {
 		throw new Error('No account settings fetched yet.')
@@ -1128,8 +1358,15 @@ const cmdSettings = async () => {
 					Quoting: quoting.value as api.Quoting,
 					ShowAddressSecurity: showAddressSecurity.checked,
 					ShowHTML: showHTML.checked,
+					RemoteContentProxy: remoteContentProxy.checked,
 					NoShowShortcuts: !showShortcuts.checked,
 					ShowHeaders: showHeaders.value.split('\n').map(s => s.trim()).filter(s => !!s),
+					UndoSendSeconds: parseInt(undoSend.value),
+					HTMLAllowedTags: htmlAllowedTags.value.split(/[\s,]+/).filter(s => !!s),
+					HTMLAllowedAttributes: htmlAllowedAttributes.value.split(/[\s,]+/).filter(s => !!s),
+					Labels: accountSettings.Labels, // Not changed by saving settings.
+					NotifyMailboxes: notifyMailboxes.value.split('\n').map(s => s.trim()).filter(s => !!s),
+					NotifySenders: notifySenders.value.split(/[\s,]+/).filter(s => !!s),
 				}
 				await withDisabled(fieldset, client.SettingsSave(accSet))
 				accountSettings = accSet
@@ -1167,6 +1404,12 @@ const cmdSettings = async () => {
 					' Show email as HTML instead of text by default for first-time senders',
 					attr.title('Whether to show HTML or text is remembered per sender. This sets the default for unknown correspondents.'),
 				),
+				dom.label(
+					style({margin: '1ex 0', display: 'block'}),
+					remoteContentProxy=dom.input(attr.type('checkbox'), accountSettings.RemoteContentProxy ? attr.checked('') : []),
+					' Load remote content in HTML messages through proxy',
+					attr.title('When showing HTML with external resources, images are fetched by the mail server, so senders do not see your IP address and browser. Tracking parameters are removed from the URLs.'),
+				),
 
 				dom.label(
 					style({margin: '1ex 0', display: 'block'}),
@@ -1174,6 +1417,15 @@ const cmdSettings = async () => {
 					' Show shortcut keys in bottom left after interaction with mouse',
 				),
 
+				dom.label(
+					style({margin: '1ex 0', display: 'block'}),
+					dom.div('Undo send'),
+					attr.title('Hold back delivery of sent messages for a few seconds, during which sending can be undone.'),
+					undoSend=dom.select(
+						[0, 5, 10, 20, 30].map(n => dom.option(attr.value(''+n), n ? n+' seconds' : 'Disabled', accountSettings.UndoSendSeconds === n ? attr.selected('') : [])),
+					),
+				),
+
 				dom.label(
 					style({margin: '1ex 0', display: 'block'}),
 					dom.div('Show additional headers'),
@@ -1185,6 +1437,52 @@ const cmdSettings = async () => {
 					dom.div(style({fontStyle: 'italic'}), 'One header name per line, for example Delivered-To, X-Mox-Reason, User-Agent, ...; Refresh mailbox view for changes to take effect.'),
 				),
 
+				dom.label(
+					style({margin: '1ex 0', display: 'block'}),
+					dom.div('Allowed HTML elements'),
+					attr.title('HTML elements kept in messages sent with HTML and in HTML messages shown, others are removed. If empty, a default set of formatting elements is used for sent messages, and shown messages only have scripts removed.'),
+					htmlAllowedTags=dom.input(attr.value((accountSettings.HTMLAllowedTags || []).join(' ')), style({width: '100%'}), attr.placeholder('p a img table ...')),
+				),
+				dom.label(
+					style({margin: '1ex 0', display: 'block'}),
+					dom.div('Allowed HTML attributes'),
+					attr.title('HTML attributes kept in messages sent with HTML and in HTML messages shown. Event handlers and links with unsafe schemes like "javascript:" are always removed.'),
+					htmlAllowedAttributes=dom.input(attr.value((accountSettings.HTMLAllowedAttributes || []).join(' ')), style({width: '100%'}), attr.placeholder('href src alt style ...')),
+				),
+
+				dom.div(
+					style({marginTop: '2ex'}),
+					'Notifications about new messages in this browser, also when the webmail is not open.',
+					dom.br(),
+					dom.clickbutton('Enable', attr.title('Your browser will ask for permission to show notifications. Notifications are sent through the push service of your browser vendor, typically only on HTTPS URLs.'), async function click(e: MouseEvent) {
+						await withStatus('Enabling notifications', webPushSubscribe(), e.target! as HTMLButtonElement)
+						window.alert('Notifications enabled for this browser.')
+					}),
+					' ',
+					dom.clickbutton('Disable', async function click(e: MouseEvent) {
+						await withStatus('Disabling notifications', webPushUnsubscribe(), e.target! as HTMLButtonElement)
+						window.alert('Notifications disabled for this browser.')
+					}),
+				),
+				dom.label(
+					style({margin: '1ex 0', display: 'block'}),
+					dom.div('Notify about new messages in mailboxes'),
+					attr.title('If empty, only new messages in the Inbox cause a notification.'),
+					notifyMailboxes=dom.textarea(
+						new String((accountSettings.NotifyMailboxes || []).join('\n')),
+						style({width: '100%'}),
+						attr.rows(''+Math.max(2, 1+(accountSettings.NotifyMailboxes || []).length)),
+						attr.placeholder('Inbox'),
+					),
+					dom.div(style({fontStyle: 'italic'}), 'One mailbox name per line.'),
+				),
+				dom.label(
+					style({margin: '1ex 0', display: 'block'}),
+					dom.div('Notify about new messages from senders'),
+					attr.title('Email addresses, or domains starting with "@". If empty, new messages from all senders cause a notification.'),
+					notifySenders=dom.input(attr.value((accountSettings.NotifySenders || []).join(' ')), style({width: '100%'}), attr.placeholder('user@example.org @example.com ...')),
+				),
+
 
 				dom.div(
 					style({marginTop: '2ex'}),
@@ -1219,6 +1517,46 @@ const cmdSettings = async () => {
 					}),
 				),
 
+				dom.div(
+					style({marginTop: '2ex'}),
+					'Templates for composing messages, e.g. canned responses.',
+					dom.br(),
+					dom.clickbutton('Manage templates', async function click() {
+						remove()
+						await cmdTemplates()
+					}),
+				),
+
+				dom.div(
+					style({marginTop: '2ex'}),
+					'Labels on messages, with colors and their order.',
+					dom.br(),
+					dom.clickbutton('Manage labels', async function click() {
+						remove()
+						cmdLabels()
+					}),
+				),
+
+				dom.div(
+					style({marginTop: '2ex'}),
+					'Rules for incoming messages, e.g. moving messages from a mailing list to a mailbox.',
+					dom.br(),
+					dom.clickbutton('Manage rules', async function click() {
+						remove()
+						await cmdRules()
+					}),
+				),
+
+				dom.div(
+					style({marginTop: '2ex'}),
+					'Access to mailboxes given to other accounts, e.g. for sharing a support mailbox.',
+					dom.br(),
+					dom.clickbutton('Manage delegation', async function click() {
+						remove()
+						await cmdDelegations()
+					}),
+				),
+
 				dom.br(),
 				dom.div(
 					dom.submitbutton('Save'),
@@ -1228,6 +1566,727 @@ const cmdSettings = async () => {
 	)
 }
 
+// readFiles reads files, e.g. from a file input, as data URIs.
+const readFiles = (files: FileList | null): Promise<api.File[]> => {
+	return new Promise<api.File[]>((resolve, reject) => {
+		const l: api.File[] = []
+		if (!files || files.length === 0) {
+			resolve(l)
+			return
+		}
+		[...files].forEach(f => {
+			const fr = new window.FileReader()
+			fr.addEventListener('load', () => {
+				l.push({Filename: f.name, DataURI: fr.result as string})
+				if (l.length === files.length) {
+					resolve(l)
+				}
+			})
+			fr.addEventListener('error', () => {
+				reject(fr.error)
+			})
+			fr.readAsDataURL(f)
+		})
+	})
+}
+
+// Show popup to manage message templates of the account.
+const cmdTemplates = async () => {
+	const [templates, shared] = await withStatus('Fetching templates', client.Templates())
+	let l = templates || []
+
+	let listElem: HTMLElement
+	let editElem: HTMLElement
+
+	const renderList = () => {
+		dom._kids(listElem,
+			l.length === 0 ? dom.div(style({fontStyle: 'italic'}), 'No templates yet.') : dom.table(
+				l.map(t => dom.tr(
+					dom.td(t.Name),
+					dom.td(styleClasses.textMild, t.Subject),
+					dom.td(
+						dom.clickbutton('Edit', function click() { edit(t) }), ' ',
+						dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							if (!window.confirm('Are you sure you want to remove template "'+t.Name+'"?')) {
+								return
+							}
+							await withStatus('Removing template', client.TemplateRemove(t.ID), e.target! as HTMLButtonElement)
+							l = l.filter(x => x !== t)
+							renderList()
+							dom._kids(editElem)
+						}),
+					),
+				)),
+			),
+		)
+	}
+
+	const edit = (t: api.Template) => {
+		let fieldset: HTMLFieldSetElement
+		let name: HTMLInputElement
+		let subject: HTMLInputElement
+		let text: HTMLTextAreaElement
+		let files: HTMLInputElement
+		let attachmentsElem: HTMLElement
+		let attachments = t.Attachments || []
+
+		const renderAttachments = () => {
+			dom._kids(attachmentsElem, attachments.map(a => [
+				dom.span(
+					a.Filename || '(unnamed)', ' ',
+					dom.clickbutton('Remove', function click() {
+						attachments = attachments.filter(x => x !== a)
+						renderAttachments()
+					}),
+				),
+				' ',
+			]))
+		}
+
+		dom._kids(editElem,
+			dom.h2(t.ID ? 'Edit template' : 'New template'),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+					const nfiles = await readFiles(files.files)
+					const nt: api.Template = {
+						ID: t.ID,
+						Name: name.value,
+						Subject: subject.value,
+						Text: text.value,
+						Attachments: [...attachments, ...nfiles],
+					}
+					const st = await withStatus('Saving template', client.TemplateSave(nt), fieldset)
+					l = [...l.filter(x => x.ID !== st.ID), st]
+					l.sort((a, b) => a.Name < b.Name ? -1 : (a.Name > b.Name ? 1 : 0))
+					renderList()
+					dom._kids(editElem)
+				},
+				fieldset=dom.fieldset(
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						dom.div('Name'),
+						name=dom.input(attr.value(t.Name), attr.required('')),
+					),
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						attr.title('If set, replaces the subject when the template is used.'),
+						dom.div('Subject (optional)'),
+						subject=dom.input(attr.value(t.Subject), style({width: '100%'})),
+					),
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						dom.div('Text'),
+						text=dom.textarea(new String(t.Text), style({width: '100%'}), attr.rows('8')),
+						dom.div(style({fontStyle: 'italic'}), 'Placeholders: {{recipient.name}}, {{recipient.firstname}}, {{recipient.email}}, {{sender.name}}, {{sender.email}}, {{date}}.'),
+					),
+					dom.div(
+						style({margin: '1ex 0'}),
+						'Attachments ',
+						attachmentsElem=dom.span(),
+						files=dom.input(attr.type('file'), attr.multiple('')),
+					),
+					dom.div(
+						dom.submitbutton('Save'), ' ',
+						dom.clickbutton('Cancel', function click() { dom._kids(editElem) }),
+					),
+				),
+			),
+		)
+		renderAttachments()
+		name.focus()
+	}
+
+	popup(
+		css('popupTemplates', {minWidth: '30em'}),
+		style({maxWidth: '50em'}),
+		dom.h1('Templates'),
+		listElem=dom.div(),
+		dom.div(
+			style({margin: '1ex 0'}),
+			dom.clickbutton('New template', function click() {
+				edit({ID: 0, Name: '', Subject: '', Text: '', Attachments: []})
+			}),
+		),
+		editElem=dom.div(),
+		(shared || []).length === 0 ? [] : [
+			dom.h2('Shared templates'),
+			dom.div(style({fontStyle: 'italic'}), 'Configured by the administrator for domains of your addresses.'),
+			dom.ul((shared || []).map(t => dom.li(t.Name, ' ', dom.span(styleClasses.textMild, '('+t.Domain+')')))),
+		],
+	)
+	renderList()
+}
+
+// Show popup explaining the junk analysis done during delivery of a message: the
+// signals, and the words that contributed to the score of the junk filter.
+const showJunkVerdict = async (msgID: number, target: HTMLButtonElement) => {
+	const v = await withStatus('Fetching junk verdict', client.MessageJunkVerdict(msgID), target)
+	if (!v) {
+		window.alert('No junk verdict was stored for this message.')
+		return
+	}
+	const words = (l: api.MessageJunkWord[] | null | undefined) => (l || []).length === 0 ?
+		dom.div(styleClasses.textMild, 'None.') :
+		dom.ul((l || []).map(w => dom.li(w.Word + ' ', dom.span(styleClasses.textMild, '(' + w.Score.toFixed(3) + ')'))))
+	popup(
+		style({maxWidth: '50em'}),
+		dom.h1('Junk verdict'),
+		dom.p((v.Accept ? 'Accepted' : 'Rejected') + (v.Mailbox ? ' to mailbox ' + v.Mailbox : '') + ', reason: ' + v.Reason),
+		v.Classified ? [
+			dom.h2('Junk filter'),
+			dom.p('Spam score ' + v.Score.toFixed(2) + ', threshold ' + v.Threshold.toFixed(2) + (v.Significant ? '' : ', not significant due to too few known words') + '.'),
+			dom.h2('Spam words'),
+			words(v.SpamWords),
+			dom.h2('Ham words'),
+			words(v.HamWords),
+		] : dom.p('The junk filter was not used, the decision was based on the reputation of the sender.'),
+		(v.Details || []).length > 0 ? [
+			dom.h2('Signals'),
+			dom.ul((v.Details || []).map(s => dom.li(s))),
+		] : [],
+	)
+}
+
+// Show popup to manage labels: their colors, names and order. Renaming and
+// removing a label also changes the messages with the label, and the labels below
+// it in the hierarchy.
+const cmdLabels = () => {
+	let listElem: HTMLElement
+	let fieldset: HTMLFieldSetElement
+	let name: HTMLInputElement
+	let color: HTMLInputElement
+
+	const labels = () => accountSettings.Labels || []
+	const below = (name: string, label: string) => name === label || name.startsWith(label+'/')
+
+	const setLabels = (l: api.Label[]) => {
+		accountSettings = {...accountSettings, Labels: l}
+		renderList()
+	}
+
+	const save = async (e: Event, label: api.Label) => {
+		const nl = await withStatus('Saving label', client.LabelSave(label), e.target! as HTMLElement)
+		setLabels(labels().map(x => x.Name === nl.Name ? nl : x))
+	}
+
+	const move = async (e: MouseEvent, i: number, delta: number) => {
+		const l = [...labels()]
+		const [x] = l.splice(i, 1)
+		l.splice(i+delta, 0, x)
+		await withStatus('Saving label order', client.LabelsOrder(l.map(x => x.Name)), e.target! as HTMLButtonElement)
+		setLabels(l)
+	}
+
+	const renderList = () => {
+		const l = labels()
+		dom._kids(listElem,
+			l.length === 0 ? dom.div(style({fontStyle: 'italic'}), 'No labels yet.') : dom.table(
+				l.map((x, i) => dom.tr(
+					dom.td(
+						style({paddingLeft: (x.Name.split('/').length-1)+'em'}),
+						dom.span(styleClasses.keyword, keywordColor(x.Name), x.Name),
+					),
+					dom.td(
+						dom.input(attr.type('color'), attr.value(x.Color || '#ffffff'), attr.title('Color of the label.'), async function change(e: Event) {
+							await save(e, {...x, Color: (e.target! as HTMLInputElement).value})
+						}),
+						' ',
+						x.Color ? dom.clickbutton('No color', async function click(e: MouseEvent) {
+							await save(e, {...x, Color: ''})
+						}) : [],
+					),
+					dom.td(
+						dom.clickbutton('↑', attr.title('Move up.'), i === 0 ? attr.disabled('') : [], async function click(e: MouseEvent) {
+							await move(e, i, -1)
+						}),
+						' ',
+						dom.clickbutton('↓', attr.title('Move down.'), i === l.length-1 ? attr.disabled('') : [], async function click(e: MouseEvent) {
+							await move(e, i, 1)
+						}),
+						' ',
+						dom.clickbutton('Rename', async function click(e: MouseEvent) {
+							let nname = window.prompt('New name for label "'+x.Name+'", also changed on messages. Use a slash for hierarchy, e.g. "work/project".', x.Name)
+							nname = (nname || '').trim().toLowerCase()
+							if (!nname || nname === x.Name) {
+								return
+							}
+							await withStatus('Renaming label', client.LabelRename(x.Name, nname), e.target! as HTMLButtonElement)
+							const nl: api.Label[] = []
+							for (const y of labels()) {
+								const n = below(y.Name, x.Name) ? nname+y.Name.substring(x.Name.length) : y.Name
+								if (!nl.find(z => z.Name === n)) {
+									nl.push({...y, Name: n})
+								}
+							}
+							setLabels(nl)
+						}),
+						' ',
+						dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							if (!window.confirm('Are you sure you want to remove label "'+x.Name+'" and the labels below it? They are also removed from all messages.')) {
+								return
+							}
+							await withStatus('Removing label', client.LabelRemove(x.Name), e.target! as HTMLButtonElement)
+							setLabels(labels().filter(y => !below(y.Name, x.Name)))
+						}),
+					),
+				)),
+			),
+		)
+	}
+
+	popup(
+		css('popupLabels', {minWidth: '30em'}),
+		style({maxWidth: '50em'}),
+		dom.h1('Labels'),
+		listElem=dom.div(),
+		dom.h2('New label'),
+		dom.form(
+			async function submit(e: SubmitEvent) {
+				e.preventDefault()
+				e.stopPropagation()
+				const nl = await withStatus('Adding label', client.LabelSave({Name: name.value, Color: color.value}), fieldset)
+				setLabels(labels().find(x => x.Name === nl.Name) ? labels().map(x => x.Name === nl.Name ? nl : x) : [...labels(), nl])
+				name.value = ''
+			},
+			fieldset=dom.fieldset(
+				name=dom.input(attr.required(''), attr.placeholder('work/project'), attr.title('Name of the label, a slash separates levels in the hierarchy.')),
+				' ',
+				color=dom.input(attr.type('color'), attr.value('#ffffff')),
+				' ',
+				dom.submitbutton('Add'),
+			),
+		),
+	)
+	renderList()
+}
+
+// Show popup to manage rules for incoming messages.
+const cmdRules = async () => {
+	let l = (await withStatus('Fetching rules', client.Rules())) || []
+
+	let listElem: HTMLElement
+	let editElem: HTMLElement
+
+	const describe = (r: api.Rule): string => {
+		const conds: string[] = []
+		if (r.From) {
+			conds.push('from contains "'+r.From+'"')
+		}
+		if (r.To) {
+			conds.push('to/cc contains "'+r.To+'"')
+		}
+		if (r.SubjectContains) {
+			conds.push('subject contains "'+r.SubjectContains+'"')
+		}
+		if (r.HasAttachment) {
+			conds.push('has attachment')
+		}
+		if (r.ListID) {
+			conds.push('list-id contains "'+r.ListID+'"')
+		}
+		const actions: string[] = []
+		if (r.Mailbox) {
+			actions.push('move to '+r.Mailbox)
+		}
+		if (r.Delete) {
+			actions.push('delete')
+		}
+		if (r.MarkRead) {
+			actions.push('mark read')
+		}
+		if ((r.ForwardTo || []).length > 0) {
+			actions.push('forward to '+(r.ForwardTo || []).join(', '))
+		}
+		if (r.Stop) {
+			actions.push('stop')
+		}
+		return (conds.length === 0 ? 'all messages' : conds.join(' and '))+': '+actions.join(', ')
+	}
+
+	const renderList = () => {
+		dom._kids(listElem,
+			l.length === 0 ? dom.div(style({fontStyle: 'italic'}), 'No rules yet.') : dom.table(
+				l.map(r => dom.tr(
+					dom.td(r.Name, r.Disabled ? dom.span(styleClasses.textMild, ' (disabled)') : []),
+					dom.td(styleClasses.textMild, describe(r)),
+					dom.td(
+						dom.clickbutton('Edit', function click() { edit(r) }), ' ',
+						dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							if (!window.confirm('Are you sure you want to remove rule "'+r.Name+'"?')) {
+								return
+							}
+							await withStatus('Removing rule', client.RuleRemove(r.ID), e.target! as HTMLButtonElement)
+							l = l.filter(x => x !== r)
+							renderList()
+							dom._kids(editElem)
+						}),
+					),
+				)),
+			),
+		)
+	}
+
+	const edit = (r: api.Rule) => {
+		let fieldset: HTMLFieldSetElement
+		let name: HTMLInputElement
+		let position: HTMLInputElement
+		let disabled: HTMLInputElement
+		let from: HTMLInputElement
+		let to: HTMLInputElement
+		let subject: HTMLInputElement
+		let hasAttachment: HTMLInputElement
+		let listID: HTMLInputElement
+		let mailbox: HTMLInputElement
+		let markRead: HTMLInputElement
+		let forwardTo: HTMLInputElement
+		let del: HTMLInputElement
+		let stop: HTMLInputElement
+
+		const field = (label: string, e: HTMLElement, title?: string) => dom.label(
+			style({margin: '1ex 0', display: 'block'}),
+			title ? attr.title(title) : [],
+			dom.div(label),
+			e,
+		)
+		const check = (e: HTMLInputElement, label: string) => dom.label(
+			style({margin: '1ex 0', display: 'block'}),
+			e, ' ', label,
+		)
+
+		dom._kids(editElem,
+			dom.h2(r.ID ? 'Edit rule' : 'New rule'),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+					const nr: api.Rule = {
+						ID: r.ID,
+						Position: parseInt(position.value) || 0,
+						Name: name.value,
+						Disabled: disabled.checked,
+						From: from.value.trim(),
+						To: to.value.trim(),
+						SubjectContains: subject.value.trim(),
+						HasAttachment: hasAttachment.checked,
+						ListID: listID.value.trim(),
+						Mailbox: mailbox.value.trim(),
+						MarkRead: markRead.checked,
+						ForwardTo: forwardTo.value.split(/[\s,]+/).filter(s => !!s),
+						Delete: del.checked,
+						Stop: stop.checked,
+					}
+					const sr = await withStatus('Saving rule', client.RuleSave(nr), fieldset)
+					l = [...l.filter(x => x.ID !== sr.ID), sr]
+					l.sort((a, b) => a.Position !== b.Position ? a.Position - b.Position : a.ID - b.ID)
+					renderList()
+					dom._kids(editElem)
+				},
+				fieldset=dom.fieldset(
+					field('Name', name=dom.input(attr.value(r.Name), attr.required(''))),
+					field('Position', position=dom.input(attr.type('number'), attr.value(''+r.Position)), 'Rules are evaluated in ascending position.'),
+					check(disabled=dom.input(attr.type('checkbox'), r.Disabled ? attr.checked('') : []), 'Disabled'),
+					dom.h3('Conditions'),
+					dom.div(style({fontStyle: 'italic'}), 'All conditions that are set must match. Text is matched case-insensitively anywhere in the field.'),
+					field('From', from=dom.input(attr.value(r.From), style({width: '100%'})), 'Address or name in From header.'),
+					field('To or Cc', to=dom.input(attr.value(r.To), style({width: '100%'})), 'Address or name in To or Cc header.'),
+					field('Subject contains', subject=dom.input(attr.value(r.SubjectContains), style({width: '100%'}))),
+					field('List-Id', listID=dom.input(attr.value(r.ListID), style({width: '100%'})), 'Identifier of mailing list, in List-Id header.'),
+					check(hasAttachment=dom.input(attr.type('checkbox'), r.HasAttachment ? attr.checked('') : []), 'Has attachment'),
+					dom.h3('Actions'),
+					field('Move to mailbox', mailbox=dom.input(attr.value(r.Mailbox), style({width: '100%'}))),
+					check(del=dom.input(attr.type('checkbox'), r.Delete ? attr.checked('') : []), 'Delete, moving to trash'),
+					check(markRead=dom.input(attr.type('checkbox'), r.MarkRead ? attr.checked('') : []), 'Mark as read'),
+					field('Forward to', forwardTo=dom.input(attr.value((r.ForwardTo || []).join(', ')), style({width: '100%'})), 'Addresses to forward incoming messages to, as attachment. Not applied when running rules on existing messages.'),
+					check(stop=dom.input(attr.type('checkbox'), r.Stop ? attr.checked('') : []), 'Stop evaluating later rules'),
+					dom.div(
+						dom.submitbutton('Save'), ' ',
+						dom.clickbutton('Cancel', function click() { dom._kids(editElem) }),
+					),
+				),
+			),
+		)
+		name.focus()
+	}
+
+	popup(
+		css('popupRules', {minWidth: '30em'}),
+		style({maxWidth: '50em'}),
+		dom.h1('Rules'),
+		dom.div(style({fontStyle: 'italic'}), 'Rules are applied to incoming messages after delivery. Use "Run rules" in the actions of a mailbox to apply them to existing messages.'),
+		listElem=dom.div(style({margin: '1ex 0'})),
+		dom.div(
+			style({margin: '1ex 0'}),
+			dom.clickbutton('New rule', function click() {
+				const position = l.length === 0 ? 0 : l[l.length-1].Position+1
+				edit({ID: 0, Position: position, Name: '', Disabled: false, From: '', To: '', SubjectContains: '', HasAttachment: false, ListID: '', Mailbox: '', MarkRead: false, ForwardTo: [], Delete: false, Stop: false})
+			}),
+		),
+		editElem=dom.div(),
+	)
+	renderList()
+}
+
+// Manage access to mailboxes given to other accounts, and open mailboxes of other
+// accounts that gave access.
+const cmdDelegations = async () => {
+	const [given0, received0] = await withStatus('Fetching delegations', client.Delegations())
+	let given = given0 || []
+	const received = received0 || []
+
+	let givenElem: HTMLElement
+	let editElem: HTMLElement
+
+	const sendText = (send: string) => send === 'as' ? 'send as' : (send === 'onbehalf' ? 'send on behalf' : '')
+
+	const renderGiven = () => {
+		dom._kids(givenElem,
+			given.length === 0 ? dom.div(style({fontStyle: 'italic'}), 'No access given to other accounts.') : dom.table(
+				given.map(d => dom.tr(
+					dom.td(d.Account),
+					dom.td(styleClasses.textMild, [(d.Mailboxes || []).join(', '), d.Write ? 'read/write' : 'read-only', sendText(d.Send)].filter(s => !!s).join('; ')),
+					dom.td(
+						dom.clickbutton('Edit', function click() { edit(d) }), ' ',
+						dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							if (!window.confirm('Are you sure you want to remove access for account "'+d.Account+'"?')) {
+								return
+							}
+							await withStatus('Removing delegation', client.DelegationRemove(d.Account), e.target! as HTMLButtonElement)
+							given = given.filter(x => x !== d)
+							renderGiven()
+							dom._kids(editElem)
+						}),
+					),
+				)),
+			),
+		)
+	}
+
+	const edit = (d: api.Delegation) => {
+		let fieldset: HTMLFieldSetElement
+		let account: HTMLInputElement
+		let mailboxes: HTMLInputElement
+		let write: HTMLInputElement
+		let send: HTMLSelectElement
+
+		dom._kids(editElem,
+			dom.h2(d.Account ? 'Edit access' : 'Give access'),
+			dom.form(
+				async function submit(e: SubmitEvent) {
+					e.preventDefault()
+					e.stopPropagation()
+					const nd: api.Delegation = {
+						Account: account.value.trim(),
+						Mailboxes: mailboxes.value.split(',').map(s => s.trim()).filter(s => !!s),
+						Write: write.checked,
+						Send: send.value,
+					}
+					await withStatus('Saving delegation', client.DelegationSave(nd), fieldset)
+					given = [...given.filter(x => x.Account !== nd.Account), nd]
+					renderGiven()
+					dom._kids(editElem)
+				},
+				fieldset=dom.fieldset(
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						dom.div('Account'),
+						account=dom.input(attr.value(d.Account), attr.required(''), d.Account ? attr.disabled('') : []),
+					),
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						attr.title('Names of mailboxes, separated by commas. Child mailboxes are not included. Access is by name: after renaming a mailbox, update the name here.'),
+						dom.div('Mailboxes'),
+						mailboxes=dom.input(attr.value((d.Mailboxes || []).join(', ')), attr.required(''), style({width: '100%'})),
+					),
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						write=dom.input(attr.type('checkbox'), d.Write ? attr.checked('') : []),
+						' Allow marking messages as (un)read, moving between the mailboxes and deleting',
+					),
+					dom.label(
+						style({margin: '1ex 0', display: 'block'}),
+						dom.div('Sending messages with addresses of this account'),
+						send=dom.select(
+							dom.option('Not allowed', attr.value(''), d.Send === '' ? attr.selected('') : []),
+							dom.option('Send as this account', attr.value('as'), d.Send === 'as' ? attr.selected('') : []),
+							dom.option('Send on behalf of this account, with Sender header', attr.value('onbehalf'), d.Send === 'onbehalf' ? attr.selected('') : []),
+						),
+					),
+					dom.div(
+						dom.submitbutton('Save'), ' ',
+						dom.clickbutton('Cancel', function click() { dom._kids(editElem) }),
+					),
+				),
+			),
+		)
+		if (d.Account) {
+			mailboxes.focus()
+		} else {
+			account.focus()
+		}
+	}
+
+	const remove = popup(
+		css('popupDelegations', {minWidth: '30em'}),
+		style({maxWidth: '50em'}),
+		dom.h1('Delegation'),
+		dom.h2('Access given to other accounts'),
+		givenElem=dom.div(style({margin: '1ex 0'})),
+		dom.div(
+			style({margin: '1ex 0'}),
+			dom.clickbutton('Give access', function click() {
+				edit({Account: '', Mailboxes: ['Inbox'], Write: false, Send: ''})
+			}),
+		),
+		editElem=dom.div(),
+		dom.h2('Access given by other accounts'),
+		received.length === 0 ? dom.div(style({fontStyle: 'italic'}), 'No access given by other accounts.') : dom.table(
+			received.map(da => dom.tr(
+				dom.td(da.Account),
+				dom.td(
+					(da.Mailboxes || []).map(name => [
+						dom.clickbutton(name, attr.title('Open mailbox of account '+da.Account+'.'), function click() {
+							remove()
+							cmdDelegatedMailbox(da, name)
+						}),
+						' ',
+					]),
+				),
+				dom.td(styleClasses.textMild, [da.Write ? 'read/write' : 'read-only', sendText(da.Send)].filter(s => !!s).join('; ')),
+			)),
+		),
+	)
+	renderGiven()
+}
+
+// Show messages in a mailbox of another account that gave access.
+const cmdDelegatedMailbox = async (da: api.DelegatedAccess, mailbox: string) => {
+	const pageSize = 100
+	let items: api.MessageItem[] = []
+	let selected: api.MessageItem | null = null
+
+	let mailboxElem: HTMLSelectElement
+	let listElem: HTMLElement
+	let msgElem: HTMLElement
+
+	const load = async (offset: number) => {
+		const l = (await withStatus('Fetching messages', client.DelegatedMessages(da.Account, mailbox, offset, pageSize))) || []
+		items = offset === 0 ? l : [...items, ...l]
+		renderList(l.length === pageSize)
+	}
+
+	const renderList = (more: boolean) => {
+		dom._kids(listElem,
+			items.length === 0 ? dom.div(style({fontStyle: 'italic'}), 'No messages.') : dom.table(
+				style({width: '100%'}),
+				items.map(mi => dom.tr(
+					mi === selected ? style({backgroundColor: styles.highlightBackground}) : [],
+					mi.Message.Seen ? [] : style({fontWeight: 'bold'}),
+					style({cursor: 'pointer'}),
+					function click() {
+						show(mi)
+					},
+					dom.td((mi.Envelope.From || []).map(a => formatAddress(a)).join(', ')),
+					dom.td(mi.Envelope.Subject || '(no subject)'),
+					dom.td(styleClasses.textMild, style({whiteSpace: 'nowrap'}), mi.Message.Received.toLocaleString()),
+				)),
+			),
+			more ? dom.clickbutton('More...', function click() {
+				load(items.length)
+			}) : [],
+		)
+	}
+
+	const flags = async (mi: api.MessageItem, seen: boolean, target: HTMLButtonElement) => {
+		if (seen) {
+			await withStatus('Marking as read', client.DelegatedFlagsAdd(da.Account, [mi.Message.ID], ['\\seen']), target)
+		} else {
+			await withStatus('Marking as unread', client.DelegatedFlagsClear(da.Account, [mi.Message.ID], ['\\seen']), target)
+		}
+		mi.Message.Seen = seen
+		renderList(items.length % pageSize === 0)
+		show(mi)
+	}
+
+	const removeItem = (mi: api.MessageItem) => {
+		items = items.filter(x => x !== mi)
+		selected = null
+		dom._kids(msgElem)
+		renderList(false)
+	}
+
+	const show = async (mi: api.MessageItem) => {
+		selected = mi
+		renderList(items.length % pageSize === 0)
+		const pm = await withStatus('Fetching message', client.DelegatedParsedMessage(da.Account, mi.Message.ID))
+		if (selected !== mi) {
+			return
+		}
+		let moveElem: HTMLSelectElement
+		dom._kids(msgElem,
+			dom.div(
+				style({margin: '1ex 0'}),
+				dom.div(dom.b('From: '), (mi.Envelope.From || []).map(a => formatAddress(a)).join(', ')),
+				dom.div(dom.b('To: '), (mi.Envelope.To || []).map(a => formatAddress(a)).join(', ')),
+				dom.div(dom.b('Subject: '), mi.Envelope.Subject),
+				dom.div(dom.b('Received: '), mi.Message.Received.toLocaleString()),
+			),
+			da.Write ? dom.div(
+				style({margin: '1ex 0'}),
+				mi.Message.Seen ?
+					dom.clickbutton('Mark unread', async function click(e: MouseEvent) { await flags(mi, false, e.target! as HTMLButtonElement) }) :
+					dom.clickbutton('Mark read', async function click(e: MouseEvent) { await flags(mi, true, e.target! as HTMLButtonElement) }),
+				' ',
+				(da.Mailboxes || []).length <= 1 ? [] : [
+					moveElem=dom.select((da.Mailboxes || []).filter(name => name !== mailbox).map(name => dom.option(name))),
+					' ',
+					dom.clickbutton('Move', async function click(e: MouseEvent) {
+						await withStatus('Moving message', client.DelegatedMessageMove(da.Account, [mi.Message.ID], moveElem.value), e.target! as HTMLButtonElement)
+						removeItem(mi)
+					}),
+					' ',
+				],
+				dom.clickbutton('Delete', async function click(e: MouseEvent) {
+					if (!window.confirm('Are you sure you want to permanently delete this message?')) {
+						return
+					}
+					await withStatus('Deleting message', client.DelegatedMessageDelete(da.Account, [mi.Message.ID]), e.target! as HTMLButtonElement)
+					removeItem(mi)
+				}),
+			) : [],
+			dom.div(
+				dom._class('mono'),
+				style({whiteSpace: 'pre-wrap', borderTop: '1px solid', borderTopColor: styles.borderColor, paddingTop: '1ex'}),
+				(pm.Texts || []).join('\n\n'),
+			),
+		)
+	}
+
+	popup(
+		css('popupDelegatedMailbox', {minWidth: '40em'}),
+		style({maxWidth: '60em'}),
+		dom.h1('Mailbox of account ', da.Account),
+		dom.div(
+			style({margin: '1ex 0'}),
+			mailboxElem=dom.select(
+				(da.Mailboxes || []).map(name => dom.option(name, name === mailbox ? attr.selected('') : [])),
+				async function change() {
+					mailbox = mailboxElem.value
+					selected = null
+					dom._kids(msgElem)
+					await load(0)
+				},
+			),
+			' ',
+			da.Write ? '' : dom.span(styleClasses.textMild, 'Read-only access.'),
+		),
+		listElem=dom.div(style({maxHeight: '20em', overflowY: 'auto'})),
+		msgElem=dom.div(),
+	)
+	await load(0)
+}
+
 // Show help popup, with shortcuts and basic explanation.
 const cmdHelp = async () => {
 	popup(
@@ -1245,6 +2304,7 @@ const cmdHelp = async () => {
 						['?', 'help'],
 						['ctrl ?', 'tooltip for focused element'],
 						['ctrl m', 'focus message'],
+						['B', 'apply action to all messages matching mailbox or search'],
 					].map(t => dom.tr(dom.td(t[0]), dom.td(t[1]))),
 
 					dom.tr(dom.td(attr.colspan('2'), dom.h2('Mailbox', style({margin: '0'})))),
@@ -1406,6 +2466,7 @@ type ComposeOptions = {
 	cc?: string[]
 	bcc?: string[]
 	replyto?: string
+	identity?: api.Identity // Selected as From, its signature is in the body for new messages and replies.
 	subject?: string
 	isForward?: boolean
 	body?: string
@@ -1418,6 +2479,7 @@ type ComposeOptions = {
 	isList?: boolean
 	editOffset?: number // For cursor, default at start.
 	draftMessageID?: number // For composing for existing draft message, to be removed when message is sent.
+	draft?: api.Draft // Autosaved draft to continue composing, removed when message is sent.
 	archiveReferenceMailboxID?: number // For "send and archive", the mailbox from which to move messages to the archive mailbox.
 }
 
@@ -1459,47 +2521,42 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 	let body: HTMLTextAreaElement
 	let attachments: HTMLInputElement
 	let requiretls: HTMLSelectElement
+	let pgpElem: HTMLElement, pgpSign: HTMLInputElement, pgpEncrypt: HTMLInputElement, pgpPassphrase: HTMLInputElement
 
 	let toBtn: HTMLButtonElement, ccBtn: HTMLButtonElement, bccBtn: HTMLButtonElement, replyToBtn: HTMLButtonElement, customFromBtn: HTMLButtonElement
 	let replyToCell: HTMLElement, toCell: HTMLElement, ccCell: HTMLElement, bccCell: HTMLElement // Where we append new address views.
 	let toRow: HTMLElement, replyToRow: HTMLElement, ccRow: HTMLElement, bccRow: HTMLElement // We show/hide rows as needed.
 	let toViews: AddrView[] = [], replytoViews: AddrView[] = [], ccViews: AddrView[] = [], bccViews: AddrView[] = []
 	let forwardAttachmentViews: ForwardAttachmentView[] = []
-
-	// todo future: upload attachments with draft messages. would mean we let users remove them again too.
-
-	// We automatically save drafts 1m after a change. When closing window, we ask to
-	// save unsaved change to draft.
+	let attachOriginal: HTMLInputElement | null = null
+	let templateAttachments: api.File[] = []
+	let templateAttachmentsElem: HTMLElement
+	let templateElem: HTMLElement
+	let templateSelect: HTMLSelectElement
+	let templateRefs: api.TemplateRef[] = []
+
+	// Compose state is autosaved on the server a few seconds after a change, with
+	// uploaded attachments, so it can be continued after closing the browser or on
+	// another device. The version of the draft detects changes made elsewhere.
+	let draft: api.Draft | null = opts.draft || null
+	let draftAttachmentsElem: HTMLElement
+	let attachmentsPromise: Promise<void> = Promise.resolve()
+	let autosaveTimer = 0
+	let autosavePromise: Promise<void> = Promise.resolve()
+
+	// Drafts can also be saved as message to the Drafts mailbox, explicitly or when
+	// closing the window. When closing window, we ask to save unsaved changes to
+	// draft.
 	let draftMessageID = opts.draftMessageID || 0
-	let draftSaveTimer = 0
 	let draftSavePromise = Promise.resolve(0)
 	let draftLastText = opts.body
 
-	const draftCancelSaveTimer = () => {
-		if (draftSaveTimer) {
-			window.clearTimeout(draftSaveTimer)
-			draftSaveTimer = 0
-		}
-	}
-
-	const draftScheduleSave = () => {
-		if (draftSaveTimer || body.value === draftLastText) {
-			return
-		}
-		draftSaveTimer = window.setTimeout(async () => {
-			draftSaveTimer = 0
-			await withStatus('Saving draft', draftSave())
-			draftScheduleSave()
-		}, 60*1000)
-	}
-
-	const draftSave = async () => {
-		draftCancelSaveTimer()
+	const composeMessage = (): api.ComposeMessage => {
 		let replyTo = ''
 		if (replytoViews && replytoViews.length === 1 && replytoViews[0].input.value) {
 			replyTo = replytoViews[0].input.value
 		}
-		const cm: api.ComposeMessage = {
+		return {
 			From: customFrom ? customFrom.value : from.value,
 			To: toViews.map(v => v.input.value).filter(s => s),
 			Cc: ccViews.map(v => v.input.value).filter(s => s),
@@ -1509,7 +2566,98 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 			TextBody: body.value,
 			ResponseMessageID: opts.responseMessageID || 0,
 			DraftMessageID: draftMessageID,
+			DraftID: draft?.ID || 0,
+		}
+	}
+
+	const draftCancelSaveTimer = () => {
+		if (autosaveTimer) {
+			window.clearTimeout(autosaveTimer)
+			autosaveTimer = 0
+		}
+	}
+
+	const draftScheduleSave = () => {
+		if (autosaveTimer) {
+			return
+		}
+		autosaveTimer = window.setTimeout(async () => {
+			autosaveTimer = 0
+			autosavePromise = draftAutosave()
+			try {
+				await autosavePromise
+			} catch (err) {
+				console.log('autosaving draft', err)
+			} finally {
+				autosavePromise = Promise.resolve()
+			}
+		}, 5*1000)
+	}
+
+	// Load the compose fields from a draft, e.g. after it was changed elsewhere.
+	const draftLoad = (d: api.Draft) => {
+		draft = d
+		subject.value = d.Message.Subject
+		subjectAutosize.dataset.value = subject.value
+		body.value = d.Message.TextBody
+		renderDraftAttachments()
+		checkAttachments()
+	}
+
+	// Save compose state as autosaved draft. If the draft was changed elsewhere, the
+	// user can choose to continue with that version, or overwrite it.
+	const draftAutosave = async () => {
+		const d: api.Draft = {
+			ID: draft?.ID || 0,
+			Version: draft?.Version || 0,
+			Updated: new Date(),
+			Message: composeMessage(),
+			Attachments: [],
 		}
+		try {
+			draft = await client.DraftSave(d)
+		} catch (err) {
+			if ((err as any).code !== 'user:draftConflict') {
+				throw err
+			}
+			const other = (await client.Drafts() || []).find(x => x.ID === d.ID)
+			if (!other) {
+				throw err
+			}
+			if (window.confirm('This draft was changed in another window or on another device. Continue with that version? Cancel to overwrite it with this version.')) {
+				draftLoad(other)
+			} else {
+				d.Version = other.Version
+				draft = await client.DraftSave(d)
+			}
+		}
+	}
+
+	// Upload the files selected in the file input as attachments of the autosaved
+	// draft, creating the draft if needed.
+	const draftAttachmentsUpload = async () => {
+		const files = await readFiles(attachments.files)
+		attachments.value = ''
+		if (files.length === 0) {
+			return
+		}
+		draftCancelSaveTimer()
+		await autosavePromise
+		if (!draft) {
+			await draftAutosave()
+		}
+		for (const f of files) {
+			const a = await client.DraftAttachmentAdd(draft!.ID, f)
+			draft!.Attachments = [...(draft!.Attachments || []), a]
+			renderDraftAttachments()
+		}
+	}
+
+	const draftSave = async () => {
+		draftCancelSaveTimer()
+		await autosavePromise
+		await attachmentsPromise
+		const cm = composeMessage()
 		const mbdrafts = listMailboxes().find(mb => mb.Draft)
 		if (!mbdrafts) {
 			throw new Error('no designated drafts mailbox')
@@ -1526,7 +2674,7 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 	// todo future: on visibilitychange with visibilityState "hidden", use navigator.sendBeacon to save latest modified draft message?
 
 	// When window is closed, ask user to cancel due to unsaved changes.
-	const unsavedChanges = () => opts.body !== body.value && (!draftMessageID || draftLastText !== body.value)
+	const unsavedChanges = () => (opts.body !== body.value || (!opts.draft && (draft?.Attachments || []).length > 0)) && (!draftMessageID || draftLastText !== body.value)
 
 	// In Firefox, ctrl-w doesn't seem interceptable when focus is on a button. It is
 	// when focus is on a textarea or not any specific UI element. So this isn't always
@@ -1534,6 +2682,8 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 	// unsavedChanges to protect the user in such cases.
 	const cmdClose = async () => {
 		draftCancelSaveTimer()
+		await autosavePromise
+		await attachmentsPromise
 		await draftSavePromise
 		if (unsavedChanges()) {
 			const action = await new Promise<string>((resolve) => {
@@ -1570,6 +2720,11 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 				return
 			}
 		}
+		// The autosaved draft is no longer needed: it was either saved to the Drafts
+		// mailbox or discarded.
+		if (draft) {
+			await withStatus('Removing autosaved draft', client.DraftRemove(draft.ID))
+		}
 		composeElem.remove()
 		composeView = null
 	}
@@ -1582,29 +2737,10 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 
 	const submit = async (archive: boolean) => {
 		draftCancelSaveTimer()
+		await autosavePromise
+		await attachmentsPromise
 		await draftSavePromise
 
-		const files = await new Promise<api.File[]>((resolve, reject) => {
-			const l: api.File[] = []
-			if (attachments.files && attachments.files.length === 0) {
-				resolve(l)
-				return
-			}
-			[...attachments.files!].forEach(f => {
-				const fr = new window.FileReader()
-				fr.addEventListener('load', () => {
-					l.push({Filename: f.name, DataURI: fr.result as string})
-					if (attachments.files && l.length === attachments.files.length) {
-						resolve(l)
-					}
-				})
-				fr.addEventListener('error', () => {
-					reject(fr.error)
-				})
-				fr.readAsDataURL(f)
-			})
-		})
-
 		let replyTo = ''
 		if (replytoViews && replytoViews.length === 1 && replytoViews[0].input.value) {
 			replyTo = replytoViews[0].input.value
@@ -1621,8 +2757,11 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 			UserAgent: 'moxwebmail/'+moxversion,
 			Subject: subject.value,
 			TextBody: body.value,
-			Attachments: files,
+			HTMLBody: '',
+			InlineFiles: [],
+			Attachments: templateAttachments,
 			ForwardAttachments: forwardAttachmentPaths.length === 0 ? {MessageID: 0, Paths: []} : {MessageID: opts.attachmentsMessageItem!.Message.ID, Paths: forwardAttachmentPaths},
+			AttachMessageID: attachOriginal?.checked ? opts.responseMessageID || 0 : 0,
 			IsForward: opts.isForward || false,
 			ResponseMessageID: opts.responseMessageID || 0,
 			RequireTLS: requiretls.value === '' ? null : requiretls.value === 'yes',
@@ -1630,10 +2769,17 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 			ArchiveThread: archive,
 			ArchiveReferenceMailboxID: opts.archiveReferenceMailboxID || 0,
 			DraftMessageID: draftMessageID,
+			DraftID: draft?.ID || 0,
+			PGPSign: pgpElem.style.display !== 'none' && pgpSign.checked,
+			PGPEncrypt: pgpElem.style.display !== 'none' && pgpEncrypt.checked,
+			PGPPassphrase: pgpPassphrase.value,
 		}
-		await client.MessageSubmit(message)
+		const result = await client.MessageSubmit(message)
 		composeElem.remove()
 		composeView = null
+		if (result.UndoUntil) {
+			showUndoSend(result)
+		}
 	}
 
 	const cmdSend = async () => {
@@ -1651,7 +2797,7 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 		if (customFrom) {
 			return
 		}
-		customFrom = dom.input(attr.value(from.value), attr.required(''), focusPlaceholder('Jane <jane@example.org>'))
+		customFrom = dom.input(attr.value(from.value), attr.required(''), focusPlaceholder('Jane <jane@example.org>'), function input() { pgpFromChanged() })
 		from.replaceWith(customFrom)
 		customFromBtn.remove()
 	}
@@ -1882,10 +3028,65 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 
 	let noAttachmentsWarning: HTMLElement
 	const checkAttachments = () => {
-		const missingAttachments = !attachments.files?.length && !forwardAttachmentViews.find(v => v.checkbox.checked) && !!body.value.split('\n').find(s => !s.startsWith('>') && s.match(/attach(ed|ment)/))
+		const missingAttachments = (draft?.Attachments || []).length === 0 && !forwardAttachmentViews.find(v => v.checkbox.checked) && !attachOriginal?.checked && templateAttachments.length === 0 && !!body.value.split('\n').find(s => !s.startsWith('>') && s.match(/attach(ed|ment)/))
 		noAttachmentsWarning.style.display = missingAttachments ? '' : 'none'
 	}
 
+	const renderTemplateAttachments = () => {
+		dom._kids(templateAttachmentsElem, templateAttachments.length === 0 ? [] : [
+			'Template attachments: ',
+			templateAttachments.map(f => [
+				dom.span(
+					f.Filename || '(unnamed)', ' ',
+					dom.clickbutton('Remove', function click() {
+						templateAttachments = templateAttachments.filter(x => x !== f)
+						renderTemplateAttachments()
+						checkAttachments()
+					}),
+				),
+				' ',
+			]),
+		])
+	}
+
+	const renderDraftAttachments = () => {
+		const l = draft?.Attachments || []
+		dom._kids(draftAttachmentsElem, l.length === 0 ? [] : [
+			'Attached: ',
+			l.map(a => [
+				dom.span(
+					a.Filename || '(unnamed)', ' ', dom.span('('+formatSize(a.Size)+') ', styleClasses.textMild),
+					dom.clickbutton('Remove', async function click(e: MouseEvent) {
+						await withStatus('Removing attachment', client.DraftAttachmentRemove(a.ID), e.target! as HTMLButtonElement)
+						if (draft) {
+							draft.Attachments = (draft.Attachments || []).filter(x => x !== a)
+						}
+						renderDraftAttachments()
+						checkAttachments()
+					}),
+				),
+				' ',
+			]),
+		])
+	}
+
+	// Insert text of template at cursor, set subject if template has one, and add its
+	// attachments.
+	const applyTemplate = async (ref: api.TemplateRef) => {
+		const to = toViews.map(v => v.input.value).filter(s => s)
+		const ct = await withStatus('Applying template', client.TemplateApply(ref, customFrom ? customFrom.value : from.value, to), fieldset)
+		if (ct.Subject) {
+			subject.value = ct.Subject
+			subjectAutosize.dataset.value = subject.value
+		}
+		body.setRangeText(ct.Text, body.selectionStart, body.selectionEnd, 'end')
+		body.focus()
+		templateAttachments.push(...(ct.Attachments || []))
+		renderTemplateAttachments()
+		checkAttachments()
+		draftScheduleSave()
+	}
+
 	const normalizeUser = (a: api.MessageAddress) => {
 		let user = a.User
 		const domconf = domainAddressConfigs[a.Domain.ASCII]
@@ -1904,15 +3105,18 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 		return accountAddresses.find(a => a.Domain.ASCII === addr.Domain.ASCII && (a.User === '' || normalizeUser(a) === normalizeUser(addr)))
 	}
 
-	let haveFrom = false
+	let haveFrom = !!opts.identity
+	const identityOptions = accountIdentities.map(ident => dom.option(identityFrom(ident), ident === opts.identity ? attr.selected('') : []))
 	const fromOptions = accountAddresses.filter(a => a.User).map(a => {
-		const selected = opts.from && opts.from.length === 1 && equalAddress(a, opts.from[0]) || loginAddress && equalAddress(a, loginAddress) && (!opts.from || envelopeIdentity(opts.from))
+		const selected = !opts.identity && (opts.from && opts.from.length === 1 && equalAddress(a, opts.from[0]) || loginAddress && equalAddress(a, loginAddress) && (!opts.from || envelopeIdentity(opts.from)))
 		const o = dom.option(formatAddress(a), selected ? attr.selected('') : [])
 		if (selected) {
 			haveFrom = true
 		}
 		return o
 	})
+	// Addresses of other accounts that allow sending as or on behalf of them.
+	const delegatedFromOptions = accountDelegated.filter(da => da.Send).map(da => (da.Addresses || []).map(addr => dom.option(addr, attr.title(da.Send === 'onbehalf' ? 'Sent on behalf of account '+da.Account+', with Sender header.' : 'Sent as account '+da.Account+'.'))))
 	if (!haveFrom && opts.from && opts.from.length === 1) {
 		const a = addressSelf(opts.from[0])
 		if (a) {
@@ -1922,6 +3126,35 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 		}
 	}
 
+	// When another identity is selected as From, its signature replaces the signature
+	// of the previous identity in the body, and its reply-to address is set.
+	let fromIdentity = opts.identity
+	const fromChanged = () => {
+		const ident = accountIdentities.find(ident => identityFrom(ident) === from.value)
+		if (ident === fromIdentity) {
+			return
+		}
+		const oldSig = identitySignature(fromIdentity)
+		const i = oldSig ? body.value.lastIndexOf(oldSig) : -1
+		if (i >= 0) {
+			body.value = body.value.substring(0, i) + identitySignature(ident) + body.value.substring(i+oldSig.length)
+		}
+		if (replytoViews.length === 1 && replytoViews[0].input.value === (fromIdentity?.ReplyTo || '')) {
+			replytoViews[0].input.value = ident?.ReplyTo || ''
+		} else if (replytoViews.length === 0 && ident?.ReplyTo) {
+			newAddrView(ident.ReplyTo, false, false, replytoViews, replyToBtn, replyToCell, replyToRow, true)
+		}
+		fromIdentity = ident
+		pgpFromChanged()
+	}
+
+	// OpenPGP options are only shown for From addresses with a private key.
+	const pgpFromChanged = () => {
+		const s = customFrom ? customFrom.value : from.value
+		const t = /<([^>]*)>\s*$/.exec(s)
+		pgpElem.style.display = pgpAddresses.includes((t ? t[1] : s).trim().toLowerCase()) ? '' : 'none'
+	}
+
 	let scheduleLink: HTMLElement
 	let scheduleElem: HTMLElement
 	let scheduleTime: HTMLInputElement
@@ -2016,7 +3249,12 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 									from=dom.select(
 										attr.required(''),
 										style({width: 'auto'}),
+										function change() {
+											fromChanged()
+										},
+										identityOptions,
 										fromOptions,
+										delegatedFromOptions,
 									),
 									' ',
 									toBtn=dom.clickbutton('To', clickCmd(cmdAddTo, shortcuts)), ' ',
@@ -2085,7 +3323,7 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 							checkAttachments()
 						}
 					},
-					!listMailboxes().find(mb => mb.Draft) ? [] : function input() {
+					function input() {
 						draftScheduleSave()
 					},
 				),
@@ -2108,8 +3346,38 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 						forwardAttachmentViews.forEach(v => v.checkbox.checked = (e.target! as HTMLInputElement).checked)
 					}), ' (Toggle all)')
 				),
+				!opts.isForward || !opts.responseMessageID ? [] : dom.label(
+					style({margin: '.5em 0', display: 'block'}),
+					attr.title('Attach the original message in full, with its headers and attachments, as .eml file.'),
+					attachOriginal=dom.input(attr.type('checkbox'), function change() { checkAttachments() }),
+					' Attach original message',
+				),
+				templateAttachmentsElem=dom.div(style({margin: '.5em 0'})),
+				draftAttachmentsElem=dom.div(style({margin: '.5em 0'})),
 				noAttachmentsWarning=dom.div(style({display: 'none'}), css('composeNoAttachmentsWarning', {backgroundColor: styles.warningBackgroundColor, padding: '0.15em .25em', margin: '.5em 0'}), 'Message mentions attachments, but no files are attached.'),
-				dom.label(style({margin: '1ex 0', display: 'block'}), 'Attachments ', attachments=dom.input(attr.type('file'), attr.multiple(''), function change() { checkAttachments() })),
+				dom.label(style({margin: '1ex 0', display: 'block'}), 'Attachments ', attachments=dom.input(attr.type('file'), attr.multiple(''), async function change() {
+					attachmentsPromise = draftAttachmentsUpload()
+					try {
+						await withStatus('Uploading attachments', attachmentsPromise)
+					} finally {
+						attachmentsPromise = Promise.resolve()
+						checkAttachments()
+					}
+				})),
+				templateElem=dom.label(
+					style({margin: '1ex 0', display: 'none'}),
+					attr.title('Insert the text of a template at the cursor. The subject is replaced if the template has one, and attachments of the template are added. Placeholders like {{recipient.firstname}} are replaced using the first To address.'),
+					'Template ',
+					templateSelect=dom.select(
+						async function change() {
+							const i = parseInt(templateSelect.value)
+							templateSelect.value = ''
+							if (!isNaN(i)) {
+								await applyTemplate(templateRefs[i])
+							}
+						},
+					),
+				),
 				dom.label(
 					style({margin: '1ex 0', display: 'block'}),
 					attr.title('How to use TLS for message delivery over SMTP:\n\nDefault: Delivery attempts follow the policies published by the recipient domain: Verification with MTA-STS and/or DANE, or optional opportunistic unverified STARTTLS if the domain does not specify a policy.\n\nWith RequireTLS: For sensitive messages, you may want to require verified TLS. The recipient destination domain SMTP server must support the REQUIRETLS SMTP extension for delivery to succeed. It is automatically chosen when the destination domain mail servers of all recipients are known to support it.\n\nFallback to insecure: If delivery fails due to MTA-STS and/or DANE policies specified by the recipient domain, and the content is not sensitive, you may choose to ignore the recipient domain TLS policies so delivery can succeed.'),
@@ -2120,6 +3388,13 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 						dom.option(attr.value('no'), 'Fallback to insecure'),
 					),
 				),
+				pgpElem=dom.div(
+					style({margin: '1ex 0', display: 'none'}),
+					attr.title('Sign the message with the OpenPGP key of the From address, and/or encrypt the message to the OpenPGP keys of the recipients. Keys of recipients are looked up in your account and in the Web Key Directory of their domain. The passphrase is needed for signing with a private key protected by a passphrase.'),
+					dom.label(pgpSign=dom.input(attr.type('checkbox')), ' Sign with OpenPGP'), ' ',
+					dom.label(pgpEncrypt=dom.input(attr.type('checkbox')), ' Encrypt with OpenPGP'), ' ',
+					pgpPassphrase=dom.input(attr.type('password'), attr.autocomplete('off'), attr.placeholder('Passphrase')),
+				),
 				dom.div(
 					scheduleLink=dom.a(attr.href(''), 'Schedule', function click(e: MouseEvent) {
 						e.preventDefault()
@@ -2203,6 +3478,33 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 	if (!opts.replyto) {
 		replyToRow.style.display = 'none'
 	}
+	if (draft) {
+		const f = draft.Message.From
+		if ([...from.options].find(o => o.value === f)) {
+			from.value = f
+		}
+		renderDraftAttachments()
+	}
+
+	// Templates are optional, the compose window is shown without waiting for them.
+	client.Templates().then(([templates, shared]) => {
+		templateRefs = [
+			...(templates || []).map(t => ({ID: t.ID, Domain: '', Name: t.Name})),
+			...(shared || []).map(t => ({ID: 0, Domain: t.Domain, Name: t.Name})),
+		]
+		if (templateRefs.length === 0) {
+			return
+		}
+		dom._kids(templateSelect,
+			dom.option(attr.value(''), 'Insert template...'),
+			templateRefs.map((ref, i) => dom.option(attr.value(''+i), ref.Name + (ref.Domain ? ' ('+ref.Domain+')' : ''))),
+		)
+		templateElem.style.display = 'block'
+	}, (err) => {
+		log('fetching templates', err)
+	})
+
+	pgpFromChanged()
 
 	document.body.appendChild(composeElem)
 	if (toViews.length > 0 && !toViews[0].input.value) {
@@ -2219,6 +3521,47 @@ const compose = (opts: ComposeOptions, listMailboxes: listMailboxes) => {
 	return composeView
 }
 
+// Show popup with autosaved drafts, e.g. of compose windows that were open when
+// the browser was closed, to continue composing or remove them.
+const autosavedDraftsPopup = (drafts: api.Draft[], listMailboxes: listMailboxes) => {
+	const remove = popup(
+		dom.h1('Autosaved drafts'),
+		dom.p('Messages were being composed but not sent.'),
+		dom.table(
+			drafts.map(d => {
+				const row = dom.tr(
+					dom.td(d.Message.Subject || '(no subject)'),
+					dom.td(styleClasses.textMild, (d.Message.To || []).join(', ')),
+					dom.td(styleClasses.textMild, style({whiteSpace: 'nowrap'}), d.Updated.toLocaleString()),
+					dom.td(
+						style({whiteSpace: 'nowrap'}),
+						dom.clickbutton('Continue', function click() {
+							remove()
+							const m = d.Message
+							compose({
+								to: m.To || [],
+								cc: m.Cc || [],
+								bcc: m.Bcc || [],
+								replyto: m.ReplyTo,
+								subject: m.Subject,
+								body: m.TextBody,
+								responseMessageID: m.ResponseMessageID,
+								draftMessageID: m.DraftMessageID,
+								draft: d,
+							}, listMailboxes)
+						}), ' ',
+						dom.clickbutton('Remove', async function click(e: MouseEvent) {
+							await withStatus('Removing autosaved draft', client.DraftRemove(d.ID), e.target! as HTMLButtonElement)
+							row.remove()
+						}),
+					),
+				)
+				return row
+			}),
+		),
+	)
+}
+
 // Show popover to edit labels for msgs.
 const labelsPopover = (e: MouseEvent, msgs: api.Message[], possibleLabels: possibleLabels): void => {
 	if (msgs.length === 0) {
@@ -2252,7 +3595,7 @@ const labelsPopover = (e: MouseEvent, msgs: api.Message[], possibleLabels: possi
 							},
 						),
 						' ',
-						dom.span(styleClasses.keyword, l),
+						dom.span(styleClasses.keyword, keywordColor(l), l),
 					),
 				)
 			),
@@ -2535,7 +3878,7 @@ const newMsgitemView = (mi: api.MessageItem, msglistView: MsglistView, otherMail
 		// Keywords are normally shown per message. For collapsed threads, we show the
 		// keywords of the thread root message as normal, and any additional keywords from
 		// children in a way that draws less attention.
-		const keywords = (m.Keywords || []).map(kw => dom.span(styleClasses.keyword, kw))
+		const keywords = (m.Keywords || []).map(kw => dom.span(styleClasses.keyword, keywordColor(kw), kw))
 		if (msgitemView.isCollapsedThreadRoot()) {
 			const keywordsSeen = new Set<string>()
 			for (const kw of (m.Keywords || [])) {
@@ -2545,7 +3888,7 @@ const newMsgitemView = (mi: api.MessageItem, msglistView: MsglistView, otherMail
 				for (const kw of (miv.messageitem.Message.Keywords || [])) {
 					if (!keywordsSeen.has(kw)) {
 						keywordsSeen.add(kw)
-						keywords.push(dom.span(styleClasses.keyword, dom._class('keywordCollapsed'), kw))
+						keywords.push(dom.span(styleClasses.keyword, dom._class('keywordCollapsed'), keywordColor(kw), kw))
 					}
 				}
 			}
@@ -2930,62 +4273,27 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 		parsedMessageReject = reject
 	})
 
-	const react = async (to: api.MessageAddress[], cc: api.MessageAddress[], bcc: api.MessageAddress[], forward: boolean) => {
-		const pm = await parsedMessagePromise
-		let body = ''
+	// The server prepares the recipients, subject and quoted text of a reply or
+	// forward. Selected text is quoted instead of the message text.
+	const react = async (mode: api.ResponseMode) => {
 		const sel = window.getSelection()
-		let haveSel = false
-		if (sel && sel.toString()) {
-			body = sel.toString()
-			haveSel = true
-		} else if (pm.Texts && pm.Texts.length > 0) {
-			body = pm.Texts[0]
-		}
-		body = body.replace(/\r/g, '').replace(/\n\n\n\n*/g, '\n\n').trim()
-		let editOffset = 0
-		if (forward) {
-			let prefix = `\n\n---- Forwarded Message ----\n`
-			const keys = ['Subject', 'Date', 'From', 'Reply-To', 'To', 'Cc']
-			const padspace = (s: string, size: number) => s + ' '.repeat(size-s.length)
-			for (const k of keys) {
-				for (const v of (pm.Headers?.[k] || [])) {
-					prefix += padspace(k + ':', 10) + v+'\n'
-				}
-			}
-			body = prefix+'\n'+body
-		} else {
-			body = body.split('\n').map(line => '> ' + line).join('\n')
-			let sig = accountSettings?.Signature || ''
-			if (!accountSettings?.Quoting && haveSel || accountSettings?.Quoting === api.Quoting.Bottom) {
-				body += '\n\n'
-				editOffset = body.length
-				body += '\n\n' + sig
-			} else {
-				let onWroteLine = ''
-				if (mi.Envelope.Date && mi.Envelope.From && mi.Envelope.From.length === 1) {
-					const from = mi.Envelope.From[0]
-					const name = from.Name || formatEmail(from)
-					const datetime = mi.Envelope.Date.toLocaleDateString(undefined, {weekday: "short", year: "numeric", month: "short", day: "numeric"}) + ' at ' + mi.Envelope.Date.toLocaleTimeString()
-					onWroteLine = 'On ' + datetime + ', ' + name + ' wrote:\n'
-				}
-				body = '\n\n' + sig + '\n' + onWroteLine + body
-			}
-		}
-		const subjectPrefix = forward ? 'Fwd:' : 'Re:'
-		let subject = mi.Envelope.Subject || ''
-		subject = (RegExp('^'+subjectPrefix, 'i').test(subject) ? '' : subjectPrefix+' ') + subject
+		const rc = await withStatus('Preparing response', client.MessageResponse(m.ID, mode, sel ? sel.toString() : ''))
+		const forward = mode === api.ResponseMode.ResponseForward
+		const ident = findIdentity(mi.Envelope.To || [])
 		const opts: ComposeOptions = {
 			from: mi.Envelope.To || undefined,
-			to: to.map(a => formatAddress(a)),
-			cc: cc.map(a => formatAddress(a)),
-			bcc: bcc.map(a => formatAddress(a)),
-			subject: subject,
-			body: body,
+			identity: ident,
+			replyto: rc.ReplyTo,
+			to: rc.To || [],
+			cc: rc.Cc || [],
+			bcc: rc.Bcc || [],
+			subject: rc.Subject,
+			body: rc.TextBody,
 			isForward: forward,
 			attachmentsMessageItem: forward ? mi : undefined,
 			responseMessageID: m.ID,
 			isList: m.IsMailingList,
-			editOffset: editOffset,
+			editOffset: rc.EditOffset,
 			// For "send and archive", we only move messages from the current open mailbox
 			// (fallback to mailbox of response message for search results) to the archive
 			// mailbox. We don't want to move messages in other mailboxes, like Sent, Trash, or
@@ -2995,46 +4303,15 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 		compose(opts, listMailboxes)
 	}
 
-	const reply = async (all: boolean) => {
-		const contains = (l: api.MessageAddress[], a: api.MessageAddress): boolean => !!l.find(e => equalAddress(e, a))
-
-		let to: api.MessageAddress[] = []
-		let cc: api.MessageAddress[] = []
-		let bcc: api.MessageAddress[] = []
-		if ((mi.Envelope.From || []).length === 1 && envelopeIdentity(mi.Envelope.From || [])) {
-			// Replying to our own message, copy the original cc/bcc.
-			to = mi.Envelope.To || []
-		} else {
-			if (mi.Envelope.ReplyTo && mi.Envelope.ReplyTo.length > 0) {
-				to = mi.Envelope.ReplyTo
-			} else {
-				to = mi.Envelope.From || []
-			}
-			if (all) {
-				for (const a of (mi.Envelope.To || [])) {
-					if (!contains(to, a) && !envelopeIdentity([a])) {
-						to.push(a)
-					}
-				}
-			}
-		}
-		if (all) {
-			cc = mi.Envelope.CC || []
-			bcc = mi.Envelope.BCC || []
-		}
-		cc = cc.filter((a, i) => !envelopeIdentity([a]) && !contains(to, a) && !contains(cc.slice(0, i), a))
-		bcc = bcc.filter(a => !envelopeIdentity([a]))
-		await react(to, cc, bcc, false)
-	}
-	const cmdForward = async () => { react([], [], [], true) }
+	const cmdForward = async () => { await react(api.ResponseMode.ResponseForward) }
 	const cmdReplyList = async () => {
 		const pm = await parsedMessagePromise
 		if (pm.ListReplyAddress) {
-			await react([pm.ListReplyAddress], [], [], false)
+			await react(api.ResponseMode.ResponseReplyList)
 		}
 	}
-	const cmdReply = async () => { await reply(false) }
-	const cmdReplyAll = async () => { await reply(true) }
+	const cmdReply = async () => { await react(api.ResponseMode.ResponseReply) }
+	const cmdReplyAll = async () => { await react(api.ResponseMode.ResponseReplyAll) }
 	const cmdPrint = async () => {
 		if (urlType) {
 			window.open('msg/'+m.ID+'/msg'+urlType+'#print', '_blank')
@@ -3061,6 +4338,10 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 		window.open('msg/'+m.ID+'/viewtext/'+[0, ...path].join('.'), '_blank')
 	}
 	const cmdDownloadRaw = async () => { window.open('msg/'+m.ID+'/rawdl', '_blank') }
+	const cmdOpenPrintable = async () => {
+		window.open('msg/'+m.ID+'/print'+(urlType === 'html' || urlType === 'htmlexternal' ? '?html=true' : ''), '_blank')
+	}
+	const cmdDownloadPDF = async () => { window.open('msg/'+m.ID+'/print.pdf', '_blank') }
 	const cmdViewAttachments = async () => {
 		if (attachments.length > 0) {
 			view(attachments[0])
@@ -3168,6 +4449,21 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 		)
 	}
 
+	const blockSender = async (domain: boolean) => {
+		const from = (mi.Envelope.From || [])[0]
+		if (!from) {
+			window.alert('Message has no From address.')
+			return
+		}
+		const fromDomain = from.Domain.Unicode || from.Domain.ASCII
+		if (!window.confirm('Reject future messages from ' + (domain ? 'domain ' + fromDomain : from.User + '@' + fromDomain) + '? Blocked senders can be managed in the account settings.')) {
+			return
+		}
+		await withStatus('Blocking sender', client.SenderBlock(m.ID, domain))
+	}
+	const cmdBlockSender = async () => { await blockSender(false) }
+	const cmdBlockSenderDomain = async () => { await blockSender(true) }
+
 	const cmdUp = async () => { msgscrollElem.scrollTo({top: msgscrollElem.scrollTop - 3*msgscrollElem.getBoundingClientRect().height / 4, behavior: 'smooth'}) }
 	const cmdDown = async () => { msgscrollElem.scrollTo({top: msgscrollElem.scrollTop + 3*msgscrollElem.getBoundingClientRect().height / 4, behavior: 'smooth'}) }
 	const cmdHome = async () => { msgscrollElem.scrollTo({top: 0 }) }
@@ -3206,7 +4502,7 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 
 	let urlType: string // text, html, htmlexternal; for opening in new tab/print
 
-	let msgbuttonElem: HTMLElement, msgheaderElem: HTMLTableSectionElement, msgattachmentElem: HTMLElement, msgmodeElem: HTMLElement
+	let msgbuttonElem: HTMLElement, msgheaderElem: HTMLTableSectionElement, msgattachmentElem: HTMLElement, msgmodeElem: HTMLElement, msgpgpElem: HTMLElement, msgsmimeElem: HTMLElement, msgcalendarElem: HTMLElement, msgunsubscribeElem: HTMLElement, msgauthElem: HTMLElement, msgsenderwarningElem: HTMLElement
 	let msgheaderFullElem: HTMLTableElement // Full headers, when enabled.
 
 	const msgmetaElem = dom.div(
@@ -3220,8 +4516,14 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 				msgheaderElem=dom.tbody(),
 			),
 			msgheaderFullElem=dom.table(),
+			msgsenderwarningElem=dom.div(),
 			msgattachmentElem=dom.div(),
 			msgmodeElem=dom.div(),
+			msgpgpElem=dom.div(),
+			msgsmimeElem=dom.div(),
+			msgcalendarElem=dom.div(),
+			msgunsubscribeElem=dom.div(),
+			msgauthElem=dom.div(),
 		),
 		// Explicit separator that separates headers from body, to
 		// prevent HTML messages from faking UI elements.
@@ -3268,11 +4570,15 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 							[
 								dom.clickbutton('Print', attr.title('Print message, opens in new tab and opens print dialog.'), clickCmd(cmdPrint, shortcuts)),
 								dom.clickbutton('Mark Not Junk', attr.title('Mark as not junk, causing this message to be used in spam classification of new incoming messages.'), clickCmd(msglistView.cmdMarkNotJunk, shortcuts)),
+								dom.clickbutton('Block sender', attr.title('Reject future messages from the From address of this message.'), clickCmd(cmdBlockSender, shortcuts)),
+								dom.clickbutton('Block sender domain', attr.title('Reject future messages from the domain of the From address of this message.'), clickCmd(cmdBlockSenderDomain, shortcuts)),
 								dom.clickbutton('Mark Read', clickCmd(msglistView.cmdMarkRead, shortcuts)),
 								dom.clickbutton('Mark Unread', clickCmd(msglistView.cmdMarkUnread, shortcuts)),
 								dom.clickbutton('Mute thread', clickCmd(msglistView.cmdMute, shortcuts)),
 								dom.clickbutton('Unmute thread', clickCmd(msglistView.cmdUnmute, shortcuts)),
 								dom.clickbutton('Open in new tab', clickCmd(cmdOpenNewTab, shortcuts)),
+								dom.clickbutton('Open printable version in new tab', attr.title('Self-contained document with headers and the displayed part, without external resources.'), clickCmd(cmdOpenPrintable, shortcuts)),
+								dom.clickbutton('Download as PDF', attr.title('PDF with headers and text of the message, for archiving.'), clickCmd(cmdDownloadPDF, shortcuts)),
 								dom.clickbutton('Download raw original message', clickCmd(cmdDownloadRaw, shortcuts)),
 								dom.clickbutton('Export as ...', function click(e: {target: HTMLElement}) {
 									popoverExport(e.target, '', [m.ID])
@@ -3467,7 +4773,9 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 						const eye = '👁'
 						const dl = '⤓' // \u2913, actually ⭳ \u2b73 would be better, but in fewer fonts (at least macos)
 						const dlurl = 'msg/'+m.ID+'/download/'+[0].concat(a.Path || []).join('.')
-						const viewbtn = dom.clickbutton(eye, viewable ? ' '+name : style({padding: '0px 0.25em'}), attr.title('View this file. Size: '+size), style({lineHeight: '1.5'}), function click() {
+						// Small server-generated thumbnail, removed if none could be generated, e.g. for PDFs without image.
+						const thumb = isImage(a) || isPDF(a) ? dom.img(attr.src('msg/'+m.ID+'/thumbnail/'+[0].concat(a.Path || []).join('.')+'?size=small'), style({maxHeight: '1.5em', maxWidth: '3em', verticalAlign: 'middle', marginRight: '.25em'}), function error(e: Event) { (e.target as HTMLElement).remove() }) : []
+						const viewbtn = dom.clickbutton(eye, viewable ? [' ', thumb, name] : style({padding: '0px 0.25em'}), attr.title('View this file. Size: '+size), style({lineHeight: '1.5'}), function click() {
 							view(a)
 						})
 						const dlbtn = dom.a(dom._class('button'), attr.download(''), attr.href(dlurl), dl, viewable ? style({padding: '0px 0.25em'}) : ' '+name, attr.title('Download this file. Size: '+size), style({lineHeight: '1.5'}))
@@ -3500,11 +4808,13 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 			(pm.Texts || []).map(t => renderText(t.replace(/\r\n/g, '\n'))),
 			(mi.Attachments || []).filter(f => isImage(f)).map(f => {
 				const pathStr = [0].concat(f.Path || []).join('.')
+				// Scaled down on the server, except GIFs which may be animated.
+				const isGIF = (f.Part.MediaType+'/'+f.Part.MediaSubType).toLowerCase() === 'image/gif'
 				return dom.div(
 					dom.div(
 						css('msgAttachmentBox', {flexGrow: 1, display: 'flex', alignItems: 'center', justifyContent: 'center', maxHeight: 'calc(100% - 50px)'}),
 						dom.img(
-							attr.src('msg/'+m.ID+'/view/'+pathStr),
+							attr.src(isGIF ? 'msg/'+m.ID+'/view/'+pathStr : 'msg/'+m.ID+'/thumbnail/'+pathStr+'?size=large'),
 							attr.title(f.Filename),
 							css('msgInlineImage', {boxShadow: styles.boxShadow, maxWidth: '100%', maxHeight: '100%'})
 						),
@@ -3542,6 +4852,252 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 		renderAttachments() // Rerender opaciy on inline images.
 	}
 
+	// Signatures of OpenPGP messages are verified automatically. Decrypting may need
+	// the passphrase of the private key, so is done on request.
+	const loadPGP = (): void => {
+		if (!mi.IsSigned && !mi.IsEncrypted) {
+			return
+		}
+		const pgpStyle = css('msgPGP', {padding: '0 .15em', margin: '0 .25em 0 0'})
+		let passphrase: HTMLInputElement
+		let fieldset: HTMLFieldSetElement
+		const render = (r: api.PGPResult) => {
+			let status: string
+			let ok = false
+			if (!r.Signed) {
+				status = 'Not signed'
+			} else if (r.SignatureValid) {
+				ok = r.SignerFrom
+				status = 'Valid OpenPGP signature by key ' + r.SignerKeyID + ((r.SignerAddresses || []).length > 0 ? ' of ' + (r.SignerAddresses || []).join(', ') : '') + (r.SignerKeySource === 'wkd' ? ', from Web Key Directory' : '')
+				if (!r.SignerFrom) {
+					status += ', but key does not have the From address'
+				}
+			} else {
+				status = 'OpenPGP signature not verified: ' + r.SignatureError
+			}
+			dom._kids(msgpgpElem,
+				dom.div(dom._class('pad'),
+					r.Decrypted ? dom.span('Decrypted', pgpStyle) : [],
+					dom.span(status, pgpStyle, ok ? [] : style({backgroundColor: styles.warningBackgroundColor})),
+				),
+			)
+			if (r.Decrypted) {
+				urlType = 'text'
+				dom._kids(msgscrollElem,
+					dom.div(dom._class('mono', 'textmulti'),
+						style({whiteSpace: 'pre-wrap'}),
+						(r.Texts || []).map(t => renderText(t.replace(/\r\n/g, '\n'))),
+					),
+				)
+				dom._kids(msgcontentElem, msgscrollElem)
+			}
+		}
+		if (!mi.IsEncrypted) {
+			;(async () => {
+				render(await withStatus('Verifying OpenPGP signature', client.MessagePGP(m.ID, '')))
+			})()
+			return
+		}
+		dom._kids(msgpgpElem,
+			dom.div(dom._class('pad'),
+				dom.form(
+					async function submit(e: SubmitEvent) {
+						e.preventDefault()
+						e.stopPropagation()
+						render(await withStatus('Decrypting message', client.MessagePGP(m.ID, passphrase.value), fieldset))
+					},
+					fieldset=dom.fieldset(
+						dom.span('Encrypted with OpenPGP', pgpStyle), ' ',
+						passphrase=dom.input(attr.type('password'), attr.autocomplete('off'), attr.placeholder('Passphrase'), attr.title('Passphrase of the private key, if it is protected with a passphrase.')), ' ',
+						dom.submitbutton('Decrypt'),
+					),
+				),
+			),
+		)
+	}
+
+	// Meeting invitations are shown with their details, and buttons to respond to the
+	// organizer.
+	const renderCalendar = (): void => {
+		const c = mi.Calendar
+		if (!c) {
+			return
+		}
+		const dateOpts: Intl.DateTimeFormatOptions = {weekday: 'short', year: 'numeric', month: 'short', day: 'numeric'}
+		const formatTime = (d: Date) => c.AllDay ? d.toLocaleDateString(undefined, {...dateOpts, timeZone: 'UTC'}) : d.toLocaleDateString(undefined, dateOpts) + ' ' + d.toLocaleTimeString(undefined, {hour: 'numeric', minute: '2-digit'})
+		const formatAttendee = (a: api.CalendarAttendee) => a.Name && a.Address ? a.Name + ' <' + a.Address + '>' : (a.Name || a.Address)
+		const title = ({REQUEST: 'Invitation', REPLY: 'Reply to invitation', CANCEL: 'Canceled event'} as {[method: string]: string})[c.Method] || 'Event'
+		let fieldset: HTMLFieldSetElement
+		const reply = async (status: string) => {
+			await withStatus('Sending reply to organizer', client.CalendarReply(m.ID, status), fieldset)
+		}
+		dom._kids(msgcalendarElem,
+			dom.div(dom._class('pad'),
+				dom.div(dom.b(title + ': ' + (c.Summary || '(no summary)'))),
+				dom.div('When: ' + formatTime(c.Start) + (c.End ? ' - ' + formatTime(c.End) : '')),
+				c.Location ? dom.div('Where: ' + c.Location) : [],
+				c.Organizer.Name || c.Organizer.Address ? dom.div('Organizer: ' + formatAttendee(c.Organizer)) : [],
+				(c.Attendees || []).length > 0 ? dom.div('Attendees: ' + (c.Attendees || []).map(a => formatAttendee(a) + (a.Status ? ' (' + a.Status.toLowerCase() + ')' : '')).join(', ')) : [],
+				c.Method === 'REQUEST' ? fieldset=dom.fieldset(
+					style({marginTop: '.5ex'}),
+					dom.clickbutton('Accept', async function click() { await reply('ACCEPTED') }), ' ',
+					dom.clickbutton('Tentative', async function click() { await reply('TENTATIVE') }), ' ',
+					dom.clickbutton('Decline', async function click() { await reply('DECLINED') }),
+				) : [],
+			),
+		)
+	}
+	renderCalendar()
+
+	// Messages from mailing lists with a List-Unsubscribe header can be unsubscribed
+	// from, with one-click unsubscribe or by sending a message, after confirmation.
+	const renderUnsubscribe = (): void => {
+		const u = mi.Unsubscribe
+		if (!u) {
+			return
+		}
+		const from = mi.Envelope.From && mi.Envelope.From.length > 0 ? formatEmail(mi.Envelope.From[0]) : ''
+		let fieldset: HTMLFieldSetElement
+		const unsubscribe = async () => {
+			const history = await withStatus('Looking up unsubscribe history', client.UnsubscribeHistory(from), fieldset)
+			let text = 'Unsubscribe from messages from ' + from + '?'
+			if (u.OneClick) {
+				text += '\n\nA request will be made to ' + u.URL
+			} else if (u.Mailto) {
+				text += '\n\nAn unsubscribe message will be sent to ' + u.Mailto.replace(/^mailto:/i, '').replace(/\?.*$/, '')
+			} else {
+				text += '\n\nThe unsubscribe page of the sender will be opened: ' + u.URL
+			}
+			if (history && history.length > 0) {
+				const h = history[0]
+				text += '\n\nYou already unsubscribed on ' + h.Time.toLocaleString() + (h.Error ? ', but it failed: ' + h.Error : '') + '.'
+			}
+			if (!window.confirm(text)) {
+				return
+			}
+			if (u.OneClick || u.Mailto) {
+				await withStatus('Unsubscribing', client.MessageUnsubscribe(m.ID, u.OneClick ? 'oneclick' : 'mailto'), fieldset)
+				dom._kids(msgunsubscribeElem, dom.div(dom._class('pad'), 'Unsubscribed from messages from ' + from + '.'))
+			} else {
+				window.open(u.URL, '_blank', 'noopener,noreferrer')
+			}
+		}
+		dom._kids(msgunsubscribeElem,
+			dom.div(dom._class('pad'),
+				fieldset=dom.fieldset(
+					'This message is from a mailing list. ',
+					dom.clickbutton('Unsubscribe', attr.title('Unsubscribe using the List-Unsubscribe header of the message.'), async function click() { await unsubscribe() }),
+				),
+			),
+		)
+	}
+	renderUnsubscribe()
+
+	// Results of the SPF, DKIM and DMARC checks done during delivery, and for
+	// messages in the Junk mailbox the reason they were delivered there.
+	const renderAuth = (): void => {
+		const a = mi.Auth
+		if (!a) {
+			return
+		}
+		const badStyle = style({backgroundColor: styles.warningBackgroundColor})
+		const badge = (name: string, c: api.AuthCheck, title: string) => {
+			const result = c.Result || 'none'
+			return dom.span(
+				css('msgAuthBadge', {padding: '0 .25em', marginRight: '.5em', border: '1px solid', borderColor: styles.borderColor, borderRadius: '.25em'}),
+				['fail', 'softfail', 'permerror'].includes(result) ? badStyle : [],
+				name + ' ' + result,
+				attr.title(title + (c.Domain ? '\nDomain: ' + c.Domain : '')),
+			)
+		}
+		const dkim = a.DKIM || []
+		const inJunk = !!listMailboxes().find(mb => mb.ID === m.MailboxID && mb.Junk)
+		dom._kids(msgauthElem,
+			dom.div(dom._class('pad'),
+				badge('SPF', a.SPF, 'Whether the IP address of the sending mail server is allowed to send for the domain.'),
+				dkim.length === 0 ?
+					badge('DKIM', {Result: 'none', Domain: ''}, 'Message was not DKIM-signed.') :
+					dkim.map(c => badge('DKIM', c, 'Whether a DKIM signature of the message verified.')),
+				badge('DMARC', a.DMARC, 'Whether the domain of the From address is aligned with a passing SPF or DKIM domain, and the result of the DMARC policy.'),
+				a.BIMI.Result ? badge('BIMI', a.BIMI, 'Whether the domain publishes a logo, only evaluated for messages that pass DMARC with an enforced policy.') : [],
+				a.BIMILogoPath ? dom.img(attr.src(a.BIMILogoPath), attr.title('Logo of the sender domain, for a message that passed DMARC with an enforced policy.\nDomain: ' + a.BIMI.Domain), style({height: '1.5em', width: '1.5em', verticalAlign: 'middle'}), function error(e: Event) { (e.target as HTMLElement).remove() }) : [],
+				inJunk && a.Reason ? dom.div(
+					'Delivered to Junk, reason: ' + a.Reason,
+					' ',
+					dom.clickbutton('Why?', attr.title('Show the signals and the words of the junk filter that caused the message to be marked as junk.'), async function click(e: MouseEvent) {
+						await showJunkVerdict(m.ID, e.target! as HTMLButtonElement)
+					}),
+					(a.ReasonDetails || []).length > 0 ? dom.ul(css('msgAuthReasons', {margin: '.25em 0'}), (a.ReasonDetails || []).map(s => dom.li(s))) : [],
+				) : [],
+			),
+		)
+	}
+	renderAuth()
+
+	// Warnings about a sender possibly impersonating a contact or known domain.
+	const renderSenderWarnings = (): void => {
+		const l = mi.SenderWarnings || []
+		if (l.length === 0) {
+			return
+		}
+		dom._kids(msgsenderwarningElem,
+			dom.div(dom._class('pad'),
+				css('msgSenderWarning', {backgroundColor: styles.warningBackgroundColor}),
+				attr.role('alert'),
+				dom.b('Be careful with this message. '),
+				l.map((w, i) => [i > 0 ? ' ' : [], w.Text]),
+			),
+		)
+	}
+	renderSenderWarnings()
+
+	// For S/MIME signed messages, the status of the signature is shown. The key of the
+	// first valid signature from the From address is pinned, with a warning for later
+	// messages signed with another key.
+	const loadSMIME = (pm: api.ParsedMessage): void => {
+		const s = mi.SMIME
+		if (!s) {
+			return
+		}
+		const changed = !!pm.SMIMEPinned && pm.SMIMEPinned !== s.Fingerprint
+		let status: string
+		if (!s.Valid) {
+			status = 'S/MIME signature not valid: ' + s.Error
+		} else if (!s.SignerFrom) {
+			status = 'Valid S/MIME signature, but certificate is not for the From address, only for ' + (s.SignerAddresses || []).join(', ')
+		} else {
+			status = 'Valid S/MIME signature by ' + (s.SignerAddresses || []).join(', ')
+		}
+		const c = pm.SMIMECertificate
+		const warningStyle = style({backgroundColor: styles.warningBackgroundColor})
+		dom._kids(msgsmimeElem,
+			dom.div(dom._class('pad'),
+				dom.span(
+					status,
+					css('msgSMIME', {padding: '0 .15em'}),
+					s.Valid && s.SignerFrom && !changed ? [] : warningStyle,
+					c ? attr.title('Subject: ' + c.Subject + '\nIssuer: ' + c.Issuer + '\nSerial number: ' + c.SerialNumber + '\nValid from ' + c.NotBefore.toISOString() + ' until ' + c.NotAfter.toISOString() + ((c.Chain || []).length > 0 ? '\nChain: ' + (c.Chain || []).join(' → ') : '')) : [],
+				),
+				changed ? [
+					' ',
+					dom.span('The sender previously signed with another key.', warningStyle),
+					' ',
+					dom.clickbutton('Accept new key', attr.title('Pin the key that signed this message for the sender, future messages signed with this key are shown without warning.'), async function click(e: MouseEvent) {
+						await withStatus('Pinning S/MIME key', client.SMIMEPin(m.ID), e.target! as HTMLButtonElement)
+						pm.SMIMEPinned = s.Fingerprint
+						loadSMIME(pm)
+					}),
+				] : [],
+			),
+		)
+		if (!pm.SMIMEPinned && s.Valid && s.SignerFrom) {
+			pm.SMIMEPinned = s.Fingerprint
+			;(async () => {
+				await withStatus('Pinning S/MIME key', client.SMIMEPin(m.ID))
+			})()
+		}
+	}
+
 	const mv: MsgView = {
 		root: root,
 		messageitem: mi,
@@ -3619,16 +5175,18 @@ const newMsgView = (miv: MsgitemView, msglistView: MsglistView, listMailboxes: l
 			}
 		}
 
+		loadPGP()
+		loadSMIME(pm)
 		messageLoaded()
 
-		if (!miv.messageitem.Message.Seen) {
+		if (!miv.messageitem.Message.Seen && !impersonationReadOnly) {
 			window.setTimeout(async () => {
 				if (!miv.messageitem.Message.Seen && miv.messageitem.Message.ID === msglistView.activeMessageID()) {
 					await withStatus('Marking current message as read', client.FlagsAdd([miv.messageitem.Message.ID], ['\\seen']))
 				}
 			}, 500)
 		}
-		if (!miv.messageitem.Message.Junk && !miv.messageitem.Message.Notjunk) {
+		if (!miv.messageitem.Message.Junk && !miv.messageitem.Message.Notjunk && !impersonationReadOnly) {
 			window.setTimeout(async () => {
 				const mailboxIsReject = () => !!listMailboxes().find(mb => mb.ID === miv.messageitem.Message.MailboxID && mb.Name === rejectsMailbox)
 				if (!miv.messageitem.Message.Junk && !miv.messageitem.Message.Notjunk && miv.messageitem.Message.Seen && miv.messageitem.Message.ID === msglistView.activeMessageID() && !mailboxIsReject()) {
@@ -5106,7 +6664,7 @@ interface MailboxView {
 	setKeywords: (keywords: string[]) => void
 }
 
-// Export messages to maildir/mbox in tar/tgz/zip/no container. Either all
+// Export messages to maildir/mbox in tar/tgz/zip/no container, or as PDF. Either all
 // messages, messages in from 1 mailbox, or explicit message ids.
 const popoverExport = (reference: HTMLElement, mailboxName: string, messageIDs: number[] | null) => {
 	let format: HTMLInputElement
@@ -5161,6 +6719,13 @@ const popoverExport = (reference: HTMLElement, mailboxName: string, messageIDs:
 						archive.value = 'tar'
 					}),
 				),
+				messageIDs ? dom.div(
+					'PDF ',
+					dom.submitbutton('pdf', attr.title('Export as a single PDF document, with the headers and text of each message, and a list of its attachments.'), function click() {
+						format.value = 'pdf'
+						archive.value = 'none'
+					}),
+				) : [],
 			),
 		),
 	)
@@ -5205,6 +6770,13 @@ const newMailboxView = (xmb: api.Mailbox, mailboxlistView: MailboxlistView, othe
 						await withStatus('Marking mailboxes as read', client.MailboxesMarkRead(mailboxIDs))
 					}),
 				),
+				dom.div(
+					dom.clickbutton('Run rules', attr.title('Apply the rules for incoming messages to the messages in this mailbox. Messages are not forwarded.'), async function click() {
+						remove()
+						const n = await withStatus('Running rules', client.RuleRun(mbv.mailbox.ID))
+						window.alert(n+' message(s) matched a rule.')
+					}),
+				),
 				dom.div(
 					dom.clickbutton('Create mailbox', attr.title('Create new mailbox within this mailbox.'), function click(e: MouseEvent) {
 						let fieldset: HTMLFieldSetElement
@@ -5501,11 +7073,16 @@ interface MailboxlistView {
 	setMailboxCounts: (mailboxID: number, total: number, unread: number) => void
 	setMailboxSpecialUse: (mailboxID: number, specialUse: api.SpecialUse) => void
 	setMailboxKeywords: (mailboxID: number, keywords: string[]) => void
+
+	// Saved searches, shown below the mailboxes.
+	setSavedSearches: (l: api.SavedSearch[]) => void
+	addSavedSearch: (ss: api.SavedSearch) => void
 }
 
-const newMailboxlistView = (msglistView: MsglistView, requestNewView: requestNewView, updatePageTitle: updatePageTitle, setLocationHash: setLocationHash, unloadSearch: unloadSearch, otherMailbox: otherMailbox): MailboxlistView => {
+const newMailboxlistView = (msglistView: MsglistView, requestNewView: requestNewView, updatePageTitle: updatePageTitle, setLocationHash: setLocationHash, unloadSearch: unloadSearch, otherMailbox: otherMailbox, openSearch: openSearch): MailboxlistView => {
 	let mailboxViews: MailboxView[] = []
 	let mailboxViewActive: MailboxView | null
+	let savedSearches: api.SavedSearch[] = []
 
 	// Reorder mailboxes and assign new short names and indenting. Called after changing the list.
 	const updateMailboxNames = () => {
@@ -5606,6 +7183,54 @@ const newMailboxlistView = (msglistView: MsglistView, requestNewView: requestNew
 
 	const root = dom.div()
 	const mailboxesElem = dom.div()
+	const savedSearchesElem = dom.div()
+
+	const renderSavedSearches = () => {
+		if (savedSearches.length === 0) {
+			dom._kids(savedSearchesElem)
+			return
+		}
+		dom._kids(savedSearchesElem,
+			dom.div(
+				style({marginTop: '1ex'}),
+				attr.role('region'), attr.arialabel('Saved searches'),
+				dom.h1('Saved searches', style({fontSize: 'inherit'})),
+				savedSearches.map(ss =>
+					dom.div(dom._class('mailboxItem'),
+						attr.tabindex('0'),
+						attr.title('Search: ' + ss.Search + '\nTotal messages: ' + ss.Total),
+						async function click() {
+							await withStatus('Opening saved search', openSearch(ss.Search))
+						},
+						async function keydown(e: KeyboardEvent) {
+							if (e.key === 'Enter') {
+								e.stopPropagation()
+								await withStatus('Opening saved search', openSearch(ss.Search))
+							}
+						},
+						dom.div(
+							style({padding: '.15em .25em', display: 'flex', justifyContent: 'space-between'}),
+							dom.div(ss.Name),
+							dom.div(
+								style({whiteSpace: 'nowrap'}),
+								dom.clickbutton('x', dom._class('mailboxHoverOnly'), style({padding: '0 .25em', fontSize: '.8em'}), attr.arialabel('Remove saved search.'), attr.title('Remove saved search.'), async function click(e: MouseEvent) {
+									e.stopPropagation()
+									if (!window.confirm('Are you sure you want to remove saved search "' + ss.Name + '"?')) {
+										return
+									}
+									await withStatus('Removing saved search', client.SavedSearchRemove(ss.ID))
+									savedSearches = savedSearches.filter(xss => xss.ID !== ss.ID)
+									renderSavedSearches()
+								}),
+								' ',
+								dom.b(dom._class('silenttitle'), ss.Unseen === 0 ? [] : [''+ss.Unseen, attr.title(''+ss.Unseen+' unread')]),
+							),
+						),
+					)
+				),
+			),
+		)
+	}
 
 	dom._kids(root,
 		dom.div(attr.role('region'), attr.arialabel('Mailboxes'),
@@ -5662,6 +7287,7 @@ const newMailboxlistView = (msglistView: MsglistView, requestNewView: requestNew
 			),
 			mailboxesElem,
 		),
+		savedSearchesElem,
 	)
 
 	const loadMailboxes = (mailboxes: api.Mailbox[], mbnameOpt?: string) => {
@@ -5794,6 +7420,17 @@ const newMailboxlistView = (msglistView: MsglistView, requestNewView: requestNew
 			}
 			mbv.setKeywords(keywords)
 		},
+
+		setSavedSearches: (l: api.SavedSearch[]): void => {
+			savedSearches = l
+			renderSavedSearches()
+		},
+
+		addSavedSearch: (ss: api.SavedSearch): void => {
+			savedSearches = [...savedSearches.filter(xss => xss.ID !== ss.ID), ss]
+			savedSearches.sort((a, b) => a.Name < b.Name ? -1 : 1)
+			renderSavedSearches()
+		},
 	}
 	return mblv
 }
@@ -6241,6 +7878,17 @@ const newSearchView = (searchbarElem: HTMLInputElement, mailboxlistView: Mailbox
 				),
 				dom.div(
 					style({padding: '1ex', textAlign: 'right'}),
+					dom.clickbutton('Save search', attr.title('Save the search, showing it with the mailboxes, with a count of matching messages.'), async function click(e: MouseEvent) {
+						const name = window.prompt('Name for the saved search')
+						if (!name) {
+							return
+						}
+						const [pf, pnotf, _] = parseSearch(searchbarElem.value, mailboxlistView)
+						const [f, notf] = await hashFilters(pf, pnotf)
+						const ss = await withStatus('Saving search', client.SavedSearchSave({ID: 0, Name: name, Search: searchbarElem.value, Filter: f, NotFilter: notf, Total: 0, Unseen: 0}), e.target! as HTMLButtonElement)
+						mailboxlistView.addSavedSearch(ss)
+					}),
+					' ',
 					dom.submitbutton('Search'),
 				),
 				async function submit(e: SubmitEvent) {
@@ -6311,13 +7959,17 @@ type updatePageTitle = () => void
 type setLocationHash = () => void
 type unloadSearch = () => void
 type otherMailbox = (mailboxID: number) => api.Mailbox | null
+type openSearch = (search: string) => Promise<void>
 type possibleLabels = () => string[]
 type listMailboxes = () => api.Mailbox[]
 
 const init = async () => {
 	let connectionElem: HTMLElement // SSE connection status/error. Empty when connected.
+	let quotaElem: HTMLElement // Warning when near quota or over soft limit.
+	let impersonationElem: HTMLElement // Banner while an admin is acting as the account.
 	let layoutElem: HTMLSelectElement // Select dropdown for layout.
 	let accountElem: HTMLElement
+	let sharedElem: HTMLElement // Button to open mailboxes of other accounts, if any gave access.
 	let loginAddressElem: HTMLElement
 
 	let msglistscrollElem: HTMLElement
@@ -6338,6 +7990,7 @@ const init = async () => {
 	let requestSequence = 0 // Counter for assigning requestID.
 	let requestID = 0 // Current request, server will mirror it in SSE data. If we get data for a different id, we ignore it.
 	let requestAnchorMessageID = 0 // For pagination.
+	let requestLastReceived: Date | null = null // Of last message in view, for resuming the view after reconnect.
 	let requestViewEnd = false // If true, there is no more data to fetch, no more page needed for this view.
 	let requestFilter = newFilter()
 	let requestNotFilter = newNotFilter()
@@ -6349,9 +8002,9 @@ const init = async () => {
 		const mb = mailboxlistView && mailboxlistView.activeMailbox()
 		const addr = loginAddress ? loginAddress.User+'@'+formatDomain(loginAddress.Domain) : ''
 		if (!mb) {
-			document.title = [addr, 'Mox Webmail'].join(' - ')
+			document.title = [addr, branding.Name || 'Mox Webmail'].join(' - ')
 		} else {
-			document.title = ['('+mb.Unread+') '+mb.Name, addr, 'Mox Webmail'].join(' - ')
+			document.title = ['('+mb.Unread+') '+mb.Name, addr, branding.Name || 'Mox Webmail'].join(' - ')
 		}
 	}
 
@@ -6415,6 +8068,7 @@ const init = async () => {
 		}
 
 		requestAnchorMessageID = 0
+		requestLastReceived = null
 		requestViewEnd = false
 		const bounds = msglistscrollElem.getBoundingClientRect()
 		await requestMessages(bounds, requestMsgID)
@@ -6429,7 +8083,7 @@ const init = async () => {
 		}
 		requestSequence++
 		requestID = requestSequence
-		const [f, notf] = refineFilters(requestFilter, requestNotFilter)
+		const [f, notf] = await hashFilters(...refineFilters(requestFilter, requestNotFilter))
 		const query = {
 			OrderAsc: settings.orderAsc,
 			Threading: settings.threading,
@@ -6454,23 +8108,28 @@ const init = async () => {
 		css('msgElem', {position: 'absolute', right: 0, left: 0, top: 0, bottom: 0, backgroundColor: styles.backgroundColorMild}),
 	)
 
-	// Returns possible labels based, either from active mailbox (possibly from search), or all mailboxes.
+	// Returns possible labels: the configured labels in their order, followed by
+	// keywords from the active mailbox (possibly from search), or all mailboxes.
 	const possibleLabels = (): string[] => {
-		if (requestFilter.MailboxID > 0) {
-			const mb = mailboxlistView.findMailboxByID(requestFilter.MailboxID)
-			if (mb) {
-				return mb.Keywords || []
+		const configured = (accountSettings?.Labels || []).map(l => l.Name)
+		const inUse = (): string[] => {
+			if (requestFilter.MailboxID > 0) {
+				const mb = mailboxlistView.findMailboxByID(requestFilter.MailboxID)
+				if (mb) {
+					return mb.Keywords || []
+				}
 			}
+			const all: {[key: string]: undefined} = {}
+			mailboxlistView.mailboxes().forEach(mb => {
+				for (const k of (mb.Keywords || [])) {
+					all[k] = undefined
+				}
+			})
+			const l = Object.keys(all)
+			l.sort()
+			return l
 		}
-		const all: {[key: string]: undefined} = {}
-		mailboxlistView.mailboxes().forEach(mb => {
-			for (const k of (mb.Keywords || [])) {
-				all[k] = undefined
-			}
-		})
-		const l = Object.keys(all)
-		l.sort()
-		return l
+		return [...configured, ...inUse().filter(kw => !configured.includes(kw))]
 	}
 
 	const refineKeyword = async (kw: string) => {
@@ -6496,7 +8155,13 @@ const init = async () => {
 	const listMailboxes = () => mailboxlistView.mailboxes()
 	const activeMailbox = () => mailboxlistView.activeMailbox()
 	const msglistView = newMsglistView(msgElem, activeMailbox, listMailboxes, setLocationHash, otherMailbox, possibleLabels, () => msglistscrollElem ? msglistscrollElem.getBoundingClientRect().height : 0, refineKeyword, viewportEnsureMessages)
-	const mailboxlistView = newMailboxlistView(msglistView, requestNewView, updatePageTitle, setLocationHash, unloadSearch, otherMailbox)
+	// Start a search, for opening a saved search.
+	const openSearch = async (q: string): Promise<void> => {
+		searchbarElem.value = q
+		searchbarElemBox.style.flexGrow = '4'
+		await searchView.submit()
+	}
+	const mailboxlistView = newMailboxlistView(msglistView, requestNewView, updatePageTitle, setLocationHash, unloadSearch, otherMailbox, openSearch)
 
 	let refineUnreadBtn: HTMLButtonElement, refineReadBtn: HTMLButtonElement, refineAttachmentsBtn: HTMLButtonElement, refineLabelBtn: HTMLButtonElement
 	const refineToggleActive = (btn: HTMLButtonElement | null): void => {
@@ -6563,7 +8228,7 @@ const init = async () => {
 											remove()
 										}
 										return dom.div(
-											dom.clickbutton(styleClasses.keyword, keywordButtonStyle, l, async function click() {
+											dom.clickbutton(styleClasses.keyword, keywordButtonStyle, keywordColor(l), l, async function click() {
 												await selectLabel()
 											}),
 										)
@@ -6590,6 +8255,10 @@ const init = async () => {
 			dom.div(
 				queryactivityElem=dom.span(),
 				' ',
+				dom.clickbutton('All...', attr.title('Apply an action to all messages matching the current mailbox or search, including those not loaded in the list.'), function click() {
+					shortcutCmd(cmdBulk, shortcuts)
+				}),
+				' ',
 				threadMode=dom.select(
 					attr.arialabel('Thread modes.'),
 					attr.title('Off: Threading disabled, messages are shown individually.\nOn: Group messages in threads, expanded by default except when (previously) manually collapsed.\nUnread: Only expand thread with unread messages, ignoring and not saving whether they were manually collapsed.'),
@@ -6758,11 +8427,12 @@ const init = async () => {
 
 	const cmdCompose = async () => {
 		let body = ''
-		let sig = accountSettings?.Signature || ''
+		const ident = findIdentity()
+		let sig = identitySignature(ident)
 		if (sig) {
 			body += '\n\n' + sig
 		}
-		compose({body: body, editOffset: 0}, listMailboxes)
+		compose({body: body, editOffset: 0, identity: ident, replyto: ident?.ReplyTo}, listMailboxes)
 	}
 	const cmdOpenInbox = async () => {
 		const mb = mailboxlistView.findMailboxByName('Inbox')
@@ -6780,6 +8450,79 @@ const init = async () => {
 		}
 	}
 
+	// Shown in the status while a bulk action is in progress, updated through SSE events.
+	let bulkProgressElem: HTMLElement | undefined
+
+	// Apply an action to all messages matching the current mailbox or search, also
+	// messages not loaded in the message list. The server does the work.
+	const cmdBulk = async () => {
+		if (!sseID) {
+			return
+		}
+		const [f, notf] = await hashFilters(...refineFilters(requestFilter, requestNotFilter))
+		const query: api.Query = {OrderAsc: settings.orderAsc, Threading: api.ThreadMode.ThreadOff, Filter: f, NotFilter: notf}
+
+		let fieldset: HTMLFieldSetElement
+		let label: HTMLInputElement
+		let mailbox: HTMLSelectElement
+		const apply = async (action: api.BulkAction, what: string) => {
+			try {
+				await withStatus(what, client.MessageBulk(sseID, query, action), fieldset)
+			} finally {
+				if (bulkProgressElem) {
+					bulkProgressElem.remove()
+					bulkProgressElem = undefined
+				}
+			}
+			remove()
+		}
+		const remove = popup(
+			style({maxWidth: '40em'}),
+			dom.h1('Apply to all matching messages'),
+			dom.p('Apply an action to all messages in the current mailbox or search, including messages not yet shown in the message list.'),
+			fieldset=dom.fieldset(
+				dom.div(
+					dom.clickbutton('Mark read', async function click() {
+						await apply({Op: api.BulkOp.BulkMarkRead, MailboxID: 0, Label: ''}, 'Marking messages as read')
+					}),
+					' ',
+					dom.clickbutton('Mark unread', async function click() {
+						await apply({Op: api.BulkOp.BulkMarkUnread, MailboxID: 0, Label: ''}, 'Marking messages as unread')
+					}),
+					' ',
+					dom.clickbutton('Delete permanently', async function click() {
+						if (!window.confirm('Are you sure you want to permanently delete all matching messages?')) {
+							return
+						}
+						await apply({Op: api.BulkOp.BulkDelete, MailboxID: 0, Label: ''}, 'Deleting messages')
+					}),
+				),
+				dom.form(
+					style({marginTop: '1ex'}),
+					async function submit(e: SubmitEvent) {
+						e.preventDefault()
+						e.stopPropagation()
+						await apply({Op: api.BulkOp.BulkLabel, MailboxID: 0, Label: label.value}, 'Adding label to messages')
+					},
+					dom.label('Label ', label=dom.input(attr.required(''))),
+					' ',
+					dom.submitbutton('Add label'),
+				),
+				dom.form(
+					style({marginTop: '1ex'}),
+					async function submit(e: SubmitEvent) {
+						e.preventDefault()
+						e.stopPropagation()
+						await apply({Op: api.BulkOp.BulkMove, MailboxID: parseInt(mailbox.value), Label: ''}, 'Moving messages')
+					},
+					dom.label('Mailbox ', mailbox=dom.select(listMailboxes().map(mb => dom.option(mb.Name, attr.value(''+mb.ID))))),
+					' ',
+					dom.submitbutton('Move'),
+				),
+			),
+		)
+	}
+
 	const shortcuts: {[key: string]: command} = {
 		i: cmdOpenInbox,
 		'/': cmdSearch,
@@ -6788,6 +8531,7 @@ const init = async () => {
 		c: cmdCompose,
 		'ctrl m': cmdFocusMsg,
 		'ctrl !': cmdSettings,
+		B: cmdBulk,
 	}
 
 	const topMailboxesStyle = css('topMailboxes', {backgroundColor: styles.mailboxesTopBackgroundColor})
@@ -6877,6 +8621,8 @@ const init = async () => {
 					),
 				),
 				connectionElem=dom.div(),
+				quotaElem=dom.div(),
+				impersonationElem=dom.div(),
 				statusElem=dom.div(css('status', {marginLeft: '.5em', flexGrow: '1'}), attr.role('status')),
 				dom.div(
 					style({paddingLeft: '1em'}),
@@ -6900,6 +8646,7 @@ const init = async () => {
 					' ',
 					dom.clickbutton('Settings', attr.title('Change settings for composing messages.'), clickCmd(cmdSettings, shortcuts)),
 					' ',
+					sharedElem=dom.span(),
 					accountElem=dom.span(),
 					' ',
 					loginAddressElem=dom.span(),
@@ -7210,6 +8957,7 @@ const init = async () => {
 
 
 	let eventSource: EventSource | null = null // If set, we have a connection.
+	let lastEventID = 0 // From last SSE event with an ID, for resuming the view after reconnect.
 	let connecting = false // Check before reconnecting.
 	let noreconnect = false // Set after one reconnect attempt fails.
 	let noreconnectTimer = 0 // Timer ID for resetting noreconnect.
@@ -7266,6 +9014,9 @@ const init = async () => {
 	// compose window after we received the "start" message with our addresses.
 	let openComposeOptions: ComposeOptions | undefined
 
+	// Autosaved drafts are checked once, on the first "start" message.
+	let autosavedDraftsChecked = false
+
 	const connect = async (isreconnect: boolean) => {
 		connectionElem.classList.toggle('loading', true)
 		dom._kids(connectionElem)
@@ -7304,7 +9055,7 @@ const init = async () => {
 		if (searchQuery) {
 			loadSearch(searchQuery)
 		}
-		[f, notf] = refineFilters(requestFilter, requestNotFilter)
+		[f, notf] = await hashFilters(...refineFilters(requestFilter, requestNotFilter))
 		const fetchCount = Math.max(50, 3*Math.ceil(msglistscrollElem.getBoundingClientRect().height/msglistView.itemHeight()))
 		const query = {
 			OrderAsc: settings.orderAsc,
@@ -7318,15 +9069,30 @@ const init = async () => {
 			DestMessageID: msgid,
 		}
 
-		viewSequence++
-		viewID = viewSequence
+		// After a reconnect, we try to resume the current view, getting only the changes
+		// since the last event we received instead of reloading all messages. If the
+		// server cannot resume, it starts the view from our request, and we clear the
+		// list when we get the start event.
+		let resume = ''
+		if (isreconnect && lastEventID && viewID && !requestID && requestLastReceived) {
+			const viewResume: api.ViewResume = {
+				LastEventID: lastEventID,
+				LastMessageReceived: requestLastReceived,
+				ViewEnd: requestViewEnd,
+			}
+			resume = '&resume='+encodeURIComponent(JSON.stringify(viewResume))
+		} else {
+			viewSequence++
+			viewID = viewSequence
+			requestAnchorMessageID = 0
+			requestLastReceived = null
+			requestViewEnd = false
+			clearList()
+		}
 
 		// We get an implicit query for the automatically selected mailbox or query.
 		requestSequence++
 		requestID = requestSequence
-		requestAnchorMessageID = 0
-		requestViewEnd = false
-		clearList()
 
 		const request = {
 			ID: requestID,
@@ -7344,7 +9110,7 @@ const init = async () => {
 			}
 		} catch (err) {}
 
-		eventSource = new window.EventSource('events?singleUseToken=' + encodeURIComponent(token)+'&request='+encodeURIComponent(JSON.stringify(request))+slow)
+		eventSource = new window.EventSource('events?singleUseToken=' + encodeURIComponent(token)+'&request='+encodeURIComponent(JSON.stringify(request))+resume+slow)
 		let eventID = window.setTimeout(() => dom._kids(statusElem, 'Connecting... '), 1000)
 		eventSource.addEventListener('open', (e: Event) => {
 			log('eventsource open', {e})
@@ -7372,7 +9138,7 @@ const init = async () => {
 				window.clearTimeout(eventID)
 				eventID = 0
 			}
-			document.title = ['(not connected)', loginAddress ? (loginAddress.User+'@'+formatDomain(loginAddress.Domain)) : '', 'Mox Webmail'].filter(s => s).join(' - ')
+			document.title = ['(not connected)', loginAddress ? (loginAddress.User+'@'+formatDomain(loginAddress.Domain)) : '', branding.Name || 'Mox Webmail'].filter(s => s).join(' - ')
 			dom._kids(connectionElem)
 			if (noreconnect) {
 				dom._kids(statusElem, capitalizeFirst(errmsg)+', not automatically retrying. ')
@@ -7394,6 +9160,14 @@ const init = async () => {
 			sseError('Server error: "' + errmsg + '"')
 		})
 
+		// Events that change the view carry an ID, which we send when resuming after a
+		// reconnect.
+		const trackEventID = (e: MessageEvent) => {
+			if (e.lastEventId) {
+				lastEventID = parseInt(e.lastEventId)
+			}
+		}
+
 		const checkParse = <T>(fn: () => T): T => {
 			try {
 				return fn()
@@ -7415,12 +9189,16 @@ const init = async () => {
 
 			const start = checkParse(() => api.parser.EventStart(data))
 			log('event start', start)
+			trackEventID(e)
 
 			accountSettings = start.Settings
 			connecting = false
 			sseID = start.SSEID
 			loginAddress = start.LoginAddress
 			dom._kids(accountElem, start.AccountPath ? dom.a(attr.href(start.AccountPath), 'Account') : [])
+			dom._kids(quotaElem, start.QuotaWarning ? dom.span(css('quotaWarning', {backgroundColor: styles.warningBackgroundColor, padding: '0 .15em', marginLeft: '.5em'}), start.QuotaWarning) : [])
+			impersonationReadOnly = !!start.Impersonation?.ReadOnly
+			dom._kids(impersonationElem, start.Impersonation ? dom.span(css('impersonation', {backgroundColor: styles.warningBackgroundColor, padding: '0 .15em', marginLeft: '.5em', fontWeight: 'bold'}), 'Impersonated by ' + start.Impersonation.By + (start.Impersonation.ReadOnly ? ', read-only' : ', full access') + ', until ' + start.Impersonation.End.toLocaleString()) : [])
 			const loginAddr = formatEmail(loginAddress)
 			dom._kids(loginAddressElem, loginAddr)
 			accountAddresses = start.Addresses || []
@@ -7437,9 +9215,26 @@ const init = async () => {
 				return a.User < b.User ? -1 : 1
 			})
 			domainAddressConfigs = start.DomainAddressConfigs || {}
+			accountIdentities = start.Identities || []
+			pgpAddresses = (start.PGPAddresses || []).map(s => s.toLowerCase())
+			searchIndexSalt = start.SearchIndexSalt
+			try {
+				window.localStorage.setItem('webmailsearchindexsalt', searchIndexSalt)
+			} catch (err) {
+			}
+			accountDelegated = start.Delegated || []
+			dom._kids(sharedElem, accountDelegated.length === 0 ? [] : [dom.clickbutton('Shared', attr.title('Open mailboxes of other accounts that gave access to this account.'), async function click() { await cmdDelegations() }), ' '])
 			rejectsMailbox = start.RejectsMailbox
 
-			clearList()
+			// If our view was resumed, we keep the messages we have, and only get changes.
+			if (start.Resumed) {
+				requestID = 0
+			} else {
+				requestAnchorMessageID = 0
+				requestLastReceived = null
+				requestViewEnd = false
+				clearList()
+			}
 
 			// If we were opened through a mailto: link, it's time to open the compose window.
 			if (openComposeOptions) {
@@ -7453,6 +9248,17 @@ const init = async () => {
 				})()
 			}
 
+			if (!autosavedDraftsChecked) {
+				autosavedDraftsChecked = true
+				client.Drafts().then(drafts => {
+					if (drafts && drafts.length > 0 && !composeView) {
+						autosavedDraftsPopup(drafts, listMailboxes)
+					}
+				}, (err) => {
+					log('listing autosaved drafts', err)
+				})
+			}
+
 			let mailboxName = start.MailboxName
 			let mb = (start.Mailboxes || []).find(mb => mb.Name === start.MailboxName)
 			if (mb) {
@@ -7462,6 +9268,7 @@ const init = async () => {
 				mailboxName = (start.Mailboxes || []).find(mb => mb.ID === requestFilter.MailboxID)?.Name || ''
 			}
 			mailboxlistView.loadMailboxes(start.Mailboxes || [], search.active ? undefined : mailboxName)
+			mailboxlistView.setSavedSearches(start.SavedSearches || [])
 			if (searchView.root.parentElement) {
 				searchView.ensureLoaded()
 			}
@@ -7469,8 +9276,10 @@ const init = async () => {
 			if (!mb) {
 				updatePageTitle()
 			}
-			dom._kids(queryactivityElem, 'loading...')
-			msglistscrollElem.appendChild(listloadingElem)
+			if (!start.Resumed) {
+				dom._kids(queryactivityElem, 'loading...')
+				msglistscrollElem.appendChild(listloadingElem)
+			}
 
 			// We'll clear noreconnect when we've held a connection for 5 seconds. Firefox
 			// disconnects often, on any network change including with docker container starts,
@@ -7480,6 +9289,20 @@ const init = async () => {
 				noreconnectTimer = 0
 			}, 5*1000)
 		})
+		eventSource.addEventListener('savedSearches', (e: MessageEvent) => {
+			const savedSearches = checkParse(() => api.parser.EventSavedSearches(JSON.parse(e.data)))
+			log('event savedSearches', savedSearches)
+			mailboxlistView.setSavedSearches(savedSearches.SavedSearches || [])
+		})
+		eventSource.addEventListener('bulkProgress', (e: MessageEvent) => {
+			const bulkProgress = checkParse(() => api.parser.EventBulkProgress(JSON.parse(e.data)))
+			log('event bulkProgress', bulkProgress)
+			if (!bulkProgressElem) {
+				bulkProgressElem = dom.span()
+				statusElem.appendChild(bulkProgressElem)
+			}
+			dom._kids(bulkProgressElem, 'Processed ' + bulkProgress.Done + ' of ' + bulkProgress.Total + ' messages... ')
+		})
 		eventSource.addEventListener('viewErr', async (e: MessageEvent) => {
 			const viewErr = checkParse(() => api.parser.EventViewErr(JSON.parse(e.data)))
 			log('event viewErr', viewErr)
@@ -7501,6 +9324,7 @@ const init = async () => {
 		eventSource.addEventListener('viewReset', async (e: MessageEvent) => {
 			const viewReset = checkParse(() => api.parser.EventViewReset(JSON.parse(e.data)))
 			log('event viewReset', viewReset)
+			trackEventID(e)
 			if (viewReset.ViewID !== viewID || viewReset.RequestID !== requestID) {
 				log('received viewReset for other viewID or requestID', {expected: {viewID, requestID}, got: {viewID: viewReset.ViewID, requestID: viewReset.RequestID}})
 				return
@@ -7514,6 +9338,7 @@ const init = async () => {
 		eventSource.addEventListener('viewMsgs', async (e: MessageEvent) => {
 			const viewMsgs = checkParse(() => api.parser.EventViewMsgs(JSON.parse(e.data)))
 			log('event viewMsgs', viewMsgs)
+			trackEventID(e)
 			if (viewMsgs.ViewID !== viewID || viewMsgs.RequestID !== requestID) {
 				log('received viewMsgs for other viewID or requestID', {expected: {viewID, requestID}, got: {viewID: viewMsgs.ViewID, requestID: viewMsgs.RequestID}})
 				return
@@ -7534,7 +9359,9 @@ const init = async () => {
 			}
 
 			if (viewMsgs.MessageItems && viewMsgs.MessageItems.length > 0) {
-				requestAnchorMessageID = viewMsgs.MessageItems[viewMsgs.MessageItems.length-1]![0]!.Message.ID
+				const lastmi = viewMsgs.MessageItems[viewMsgs.MessageItems.length-1]![0]!
+				requestAnchorMessageID = lastmi.Message.ID
+				requestLastReceived = lastmi.Message.Received
 			}
 			requestViewEnd = viewMsgs.ViewEnd
 			if (requestViewEnd) {
@@ -7553,7 +9380,9 @@ const init = async () => {
 		eventSource.addEventListener('viewChanges', async (e: MessageEvent) => {
 			const viewChanges = checkParse(() => api.parser.EventViewChanges(JSON.parse(e.data)))
 			log('event viewChanges', viewChanges)
-			if (viewChanges.ViewID !== viewID) {
+			trackEventID(e)
+			// ViewID 0 is for changes to mailboxes and threads of the account, for any view.
+			if (viewChanges.ViewID !== 0 && viewChanges.ViewID !== viewID) {
 				log('received viewChanges for other viewID', {expected: viewID, got: viewChanges.ViewID})
 				return
 			}
@@ -17,6 +17,8 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -340,7 +342,7 @@ func TestWebmail(t *testing.T) {
 	loginCookie.Value = api.LoginPrep(ctx)
 	reqInfo.Request.Header = http.Header{"Cookie": []string{loginCookie.String()}}
 
-	csrfToken := api.Login(ctx, loginCookie.Value, "mjl@mox.example", "test1234")
+	csrfToken := api.Login(ctx, loginCookie.Value, "mjl@mox.example", "test1234", "")
 	var sessionCookie *http.Cookie
 	for _, c := range respRec.Result().Cookies() {
 		if c.Name == "webmailsession" {
@@ -402,7 +404,7 @@ func TestWebmail(t *testing.T) {
 		}
 		rr := httptest.NewRecorder()
 		rr.Body = &bytes.Buffer{}
-		handle(apiHandler, false, "", rr, req)
+		handle(apiHandler, "/", false, "", rr, req)
 		if rr.Code != expStatusCode {
 			t.Fatalf("got status %d, expected %d (%s)", rr.Code, expStatusCode, readBody(rr.Body))
 		}
@@ -459,6 +461,7 @@ func TestWebmail(t *testing.T) {
 	testHTTP("POST", "/msg.js", httpHeaders{}, http.StatusMethodNotAllowed, nil, nil)
 	testHTTP("GET", "/text.js", httpHeaders{}, http.StatusOK, httpHeaders{ctJS}, nil)
 	testHTTP("POST", "/text.js", httpHeaders{}, http.StatusMethodNotAllowed, nil, nil)
+	testHTTP("GET", "/sw.js", httpHeaders{}, http.StatusOK, httpHeaders{ctJS}, nil)
 
 	testHTTP("POST", "/api/Bogus", httpHeaders{}, http.StatusOK, nil, noAuth)
 	testHTTP("POST", "/api/Bogus", httpHeaders{hdrCSRFBad}, http.StatusOK, nil, noAuth)
@@ -496,7 +499,7 @@ func TestWebmail(t *testing.T) {
 		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 		r.Header.Add("Cookie", cookieOK.String())
 		w := httptest.NewRecorder()
-		handle(apiHandler, false, "", w, r)
+		handle(apiHandler, "/", false, "", w, r)
 		if w.Code != http.StatusOK {
 			t.Fatalf("export, got status code %d, expected 200: %s", w.Code, w.Body.Bytes())
 		}
@@ -543,6 +546,40 @@ func TestWebmail(t *testing.T) {
 	testExport("mbox", "zip", "Lists", true, 3)
 	testExport("mbox", "zip", "Lists", false, 1)
 
+	// Export of selected messages as PDF.
+	testExportPDF := func(messageIDs string, expCode int) {
+		t.Helper()
+
+		fields := url.Values{
+			"csrf":       []string{string(csrfToken)},
+			"format":     []string{"pdf"},
+			"archive":    []string{"none"},
+			"messageids": []string{messageIDs},
+		}
+		r := httptest.NewRequest("POST", "/export", strings.NewReader(fields.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Add("Cookie", cookieOK.String())
+		w := httptest.NewRecorder()
+		handle(apiHandler, "/", false, "", w, r)
+		if w.Code != expCode {
+			t.Fatalf("export pdf, got status code %d, expected %d: %s", w.Code, expCode, w.Body.Bytes())
+		}
+		if expCode != http.StatusOK {
+			return
+		}
+		tcompare(t, w.Header().Get("Content-Type"), "application/pdf")
+		buf := w.Body.Bytes()
+		if !bytes.HasPrefix(buf, []byte("%PDF-1.4\n")) || !bytes.HasSuffix(buf, []byte("%%EOF\n")) {
+			t.Fatalf("export pdf, not a pdf document: %q", buf)
+		}
+		if !bytes.Contains(buf, []byte("/Type /Pages /Kids [5 0 R 7 0 R] /Count 2 >>")) {
+			t.Fatalf("export pdf, expected 2 pages: %q", buf)
+		}
+	}
+	testExportPDF(fmt.Sprintf("%d,%d", inboxMinimal.ID, inboxAttachments.ID), http.StatusOK)
+	testExportPDF("", http.StatusBadRequest)
+	testExportPDF(fmt.Sprintf("%d", testmsgs[len(testmsgs)-1].ID+1), http.StatusInternalServerError)
+
 	// HTTP message, generic
 	testHTTP("GET", fmt.Sprintf("/msg/%v/attachments.zip", inboxMinimal.ID), nil, http.StatusForbidden, nil, nil)
 	testHTTP("GET", fmt.Sprintf("/msg/%v/attachments.zip", inboxMinimal.ID), httpHeaders{hdrCSRFBad}, http.StatusForbidden, nil, nil)
@@ -683,6 +720,26 @@ func TestWebmail(t *testing.T) {
 		testHTTPAuthREST("GET", pathInboxHTML+"/"+elem, http.StatusBadRequest, nil, nil)
 	}
 
+	// Printable document and PDF.
+	checkContains := func(l ...string) func(resp *http.Response) {
+		return func(resp *http.Response) {
+			t.Helper()
+			buf, err := io.ReadAll(resp.Body)
+			tcheck(t, err, "reading response")
+			for _, exp := range l {
+				if !strings.Contains(string(buf), exp) {
+					t.Fatalf("response does not contain %q:\n%s", exp, buf)
+				}
+			}
+		}
+	}
+	testHTTP("GET", pathInboxAltRel+"/print", httpHeaders{}, http.StatusForbidden, nil, nil)
+	testHTTP("GET", pathInboxAltRel+"/print.pdf", httpHeaders{hdrSessionBad}, http.StatusForbidden, nil, nil)
+	testHTTPAuthREST("GET", pathInboxAltRel+"/print", http.StatusOK, httpHeaders{ctHTML, cspHTML}, checkContains("<title>test with alt and rel</title>", "altrel@mox.example", "the text body"))
+	testHTTPAuthREST("GET", pathInboxAltRel+"/print?html=true", http.StatusOK, httpHeaders{ctHTML, cspHTML}, checkContains("the body", `src="data:image/png;base64,`))
+	testHTTPAuthREST("GET", pathInboxText+"/print?html=true", http.StatusBadRequest, nil, nil)
+	testHTTPAuthREST("GET", pathInboxAltRel+"/print.pdf", http.StatusOK, httpHeaders{{"Content-Type", "application/pdf"}}, checkContains("%PDF-1.4", "(the text body) '", "%%EOF"))
+
 	// HTTP message part: view,viewtext,download
 	for _, elem := range []string{"view", "viewtext", "download"} {
 		testHTTP("GET", pathInboxAltRel+"/"+elem+"/0", httpHeaders{}, http.StatusForbidden, nil, nil)
@@ -694,6 +751,76 @@ func TestWebmail(t *testing.T) {
 		testHTTPAuthREST("GET", pathInboxAltRel+"/"+elem+"/1", http.StatusNotFound, nil, nil)
 	}
 
+	// Range requests for raw message and decoded parts.
+	checkBody := func(exp string) func(resp *http.Response) {
+		return func(resp *http.Response) {
+			t.Helper()
+			buf, err := io.ReadAll(resp.Body)
+			tcheck(t, err, "reading response")
+			tcompare(t, string(buf), exp)
+		}
+	}
+	raw, err := os.ReadFile(acc.MessagePath(inboxAttachments.ID))
+	tcheck(t, err, "read message file")
+	raw = append(slices.Clone(inboxAttachments.m.MsgPrefix), raw...)
+	testHTTP("GET", pathInboxAttachments+"/rawdl", httpHeaders{hdrSessionOK, {"Range", "bytes=0-4"}}, http.StatusPartialContent, httpHeaders{{"Content-Range", fmt.Sprintf("bytes 0-4/%d", len(raw))}, {"ETag", fmt.Sprintf(`"%d"`, inboxAttachments.ID)}}, checkBody(string(raw[:5])))
+	testHTTP("GET", pathInboxAttachments+"/download/0.1", httpHeaders{hdrSessionOK, {"Range", "bytes=1-2"}}, http.StatusPartialContent, httpHeaders{{"Content-Range", "bytes 1-2/6"}, {"Accept-Ranges", "bytes"}}, checkBody("NG"))
+	testHTTP("GET", pathInboxAttachments+"/download/0.1", httpHeaders{hdrSessionOK, {"Range", "bytes=-3"}}, http.StatusPartialContent, nil, checkBody("..."))
+	testHTTP("GET", pathInboxAttachments+"/view/0.3", httpHeaders{hdrSessionOK, {"Range", "bytes=3-"}}, http.StatusPartialContent, httpHeaders{{"Content-Disposition", `inline; filename=test.jpg`}}, checkBody("..."))
+	testHTTP("GET", pathInboxAttachments+"/view/0.3", httpHeaders{hdrSessionOK, {"Range", "bytes=10-"}}, http.StatusRequestedRangeNotSatisfiable, nil, nil)
+	testHTTPAuthREST("HEAD", pathInboxAttachments+"/download/0.1", http.StatusOK, httpHeaders{{"Content-Length", "6"}}, nil)
+
+	// HTTP message part: thumbnail. The test message parts aren't valid images.
+	testHTTP("GET", pathInboxAttachments+"/thumbnail/0.1", httpHeaders{}, http.StatusForbidden, nil, nil)
+	testHTTP("GET", pathInboxAttachments+"/thumbnail/0.1", httpHeaders{hdrSessionBad}, http.StatusForbidden, nil, nil)
+	testHTTPAuthREST("GET", pathInboxAttachments+"/thumbnail/0.1", http.StatusNotFound, nil, nil)
+	testHTTPAuthREST("GET", pathInboxAttachments+"/thumbnail/0.1?size=huge", http.StatusBadRequest, nil, nil)
+	testHTTPAuthREST("GET", pathInboxAttachments+"/thumbnail/0.9", http.StatusNotFound, nil, nil)
+	testHTTPAuthREST("POST", pathInboxAttachments+"/thumbnail/0.1", http.StatusMethodNotAllowed, nil, nil)
+
+	// Admin acting as the account, in a read-only session.
+	token, err := webauth.ImpersonateStart("webmail", "mjl", "(admin)", true, time.Hour)
+	tcheck(t, err, "start impersonation")
+	var impCookie *http.Cookie
+	var impCSRF string
+	testHTTP("GET", "/impersonate?token="+token, httpHeaders{}, http.StatusOK, httpHeaders{ctHTML}, func(resp *http.Response) {
+		for _, c := range resp.Cookies() {
+			if c.Name == "webmailsession" {
+				impCookie = c
+			}
+		}
+		buf, err := io.ReadAll(resp.Body)
+		tcheck(t, err, "read response")
+		m := regexp.MustCompile(`'csrftoken', "([^"]+)"`).FindSubmatch(buf)
+		if m != nil {
+			impCSRF = string(m[1])
+		}
+	})
+	if impCookie == nil || impCSRF == "" {
+		t.Fatalf("missing session cookie or csrf token for impersonation")
+	}
+	// Token can be used once.
+	testHTTP("GET", "/impersonate?token="+token, httpHeaders{}, http.StatusBadRequest, nil, nil)
+	hdrImp := httpHeaders{{"Cookie", (&http.Cookie{Name: "webmailsession", Value: impCookie.Value}).String()}, {"x-mox-csrf", impCSRF}}
+	testHTTP("POST", "/api/SSETypes", hdrImp, http.StatusOK, httpHeaders{ctJSON}, nil)
+	testHTTP("POST", "/api/MailboxCreate", hdrImp, http.StatusOK, httpHeaders{ctJSON}, func(resp *http.Response) {
+		userAuthError(resp, "user:forbidden")
+	})
+	testHTTP("POST", "/export", httpHeaders{hdrImp[0]}, http.StatusForbidden, nil, nil)
+	events, err := store.AuditList(ctxbg, store.AuditFilter{Kind: store.AuditImpersonate}, 10)
+	tcheck(t, err, "list audit events")
+	var actions []string
+	for _, e := range events {
+		if e.Account != "mjl" || e.Actor != "(admin)" {
+			t.Fatalf("audit event for account %q by %q", e.Account, e.Actor)
+		}
+		actions = append(actions, e.Action)
+	}
+	slices.Sort(actions)
+	if !slices.Equal(actions, []string{"MailboxCreate", "SSETypes", "start"}) {
+		t.Fatalf("audit event actions %v", actions)
+	}
+
 	// Logout invalidates the session. Must work exactly once.
 	// Normally the generic /api/ auth check returns a user error. We bypass it and
 	// check for the server error.
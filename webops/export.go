@@ -16,9 +16,10 @@ import (
 )
 
 // Export is used by webmail and webaccount to export messages of one or
-// multiple mailboxes, in maildir or mbox format, in a tar/tgz/zip archive or
-// direct mbox.
-func Export(log mlog.Log, accName string, w http.ResponseWriter, r *http.Request) {
+// multiple mailboxes, in maildir, maildir++ or mbox format, in a tar/tgz/zip
+// archive or direct mbox. The export is recorded in the audit log, with protocol
+// and remoteIP.
+func Export(log mlog.Log, accName, protocol, remoteIP string, w http.ResponseWriter, r *http.Request) {
 	if r.Method != "POST" {
 		http.Error(w, "405 - method not allowed - use post", http.StatusMethodNotAllowed)
 		return
@@ -47,7 +48,7 @@ func Export(log mlog.Log, accName string, w http.ResponseWriter, r *http.Request
 	archive := r.FormValue("archive")
 	recursive := r.FormValue("recursive") != ""
 	switch format {
-	case "maildir", "mbox":
+	case "maildir", "maildir++", "mbox", "pdf":
 	default:
 		http.Error(w, "400 - bad request - unknown format", http.StatusBadRequest)
 		return
@@ -58,14 +59,22 @@ func Export(log mlog.Log, accName string, w http.ResponseWriter, r *http.Request
 		http.Error(w, "400 - bad request - unknown archive", http.StatusBadRequest)
 		return
 	}
-	if archive == "none" && (format != "mbox" || recursive) {
-		http.Error(w, "400 - bad request - archive none can only be used with non-recursive mbox", http.StatusBadRequest)
+	if archive == "none" && (format != "mbox" && format != "pdf" || recursive) {
+		http.Error(w, "400 - bad request - archive none can only be used with non-recursive mbox or pdf", http.StatusBadRequest)
+		return
+	}
+	if len(messageIDs) > 0 && format == "maildir++" {
+		http.Error(w, "400 - bad request - cannot export message ids in maildir++ format", http.StatusBadRequest)
 		return
 	}
 	if len(messageIDs) > 0 && recursive {
 		http.Error(w, "400 - bad request - cannot export message ids recursively", http.StatusBadRequest)
 		return
 	}
+	if format == "pdf" && (len(messageIDs) == 0 || archive != "none") {
+		http.Error(w, "400 - bad request - pdf format can only be used with message ids and archive none", http.StatusBadRequest)
+		return
+	}
 
 	acc, err := store.OpenAccount(log, accName, false)
 	if err != nil {
@@ -88,6 +97,29 @@ func Export(log mlog.Log, accName string, w http.ResponseWriter, r *http.Request
 	}
 	filename := fmt.Sprintf("mailexport%s-%s", name, time.Now().Format("20060102-150405"))
 	filename += "." + format
+
+	store.AuditAdd(r.Context(), log, store.AuditEvent{
+		Kind:     store.AuditExport,
+		Account:  accName,
+		Actor:    accName,
+		Protocol: protocol,
+		RemoteIP: remoteIP,
+		Action:   "export",
+		Result:   "ok",
+		Details:  filename,
+	})
+
+	if format == "pdf" {
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		if err := ExportPDF(r.Context(), log, acc, w, messageIDs); err != nil {
+			log.Errorx("exporting mail as pdf", err)
+			w.Header().Del("Content-Disposition")
+			http.Error(w, "500 - internal server error - exporting mail as pdf", http.StatusInternalServerError)
+		}
+		return
+	}
+
 	var archiver store.Archiver
 	if archive == "none" {
 		w.Header().Set("Content-Type", "application/mbox")
@@ -117,7 +149,7 @@ func Export(log mlog.Log, accName string, w http.ResponseWriter, r *http.Request
 		log.Check(err, "exporting mail close")
 	}()
 	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
-	if err := store.ExportMessages(r.Context(), log, acc.DB, acc.Dir, archiver, format == "maildir", mailbox, messageIDs, recursive); err != nil {
+	if err := store.ExportMessages(r.Context(), log, acc.DB, acc.Dir, archiver, store.ExportFormat(format), mailbox, messageIDs, recursive); err != nil {
 		log.Errorx("exporting mail", err)
 	}
 }
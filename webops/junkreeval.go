@@ -0,0 +1,575 @@
+package webops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/dnsbl"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+// JunkMove is a message moved between the Inbox and Junk mailbox by junk
+// re-evaluation.
+type JunkMove struct {
+	MessageID int64
+	ToJunk    bool   // Moved from Inbox to Junk, otherwise from Junk to Inbox.
+	From      string // Message From address.
+	Subject   string
+	Reason    string
+}
+
+// Junk re-evaluation happens outside of API requests. Errors while moving
+// messages are raised as junkReevalError panics, and recovered.
+type junkReevalError struct {
+	err error
+}
+
+func junkReevalCheckf(ctx context.Context, err error, format string, args ...any) {
+	if err != nil {
+		panic(junkReevalError{fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), err)})
+	}
+}
+
+var junkReevalOps = XOps{
+	DBWrite: func(ctx context.Context, acc *store.Account, fn func(tx *bstore.Tx)) {
+		err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+			fn(tx)
+			return nil
+		})
+		junkReevalCheckf(ctx, err, "transaction")
+	},
+	Checkf:     junkReevalCheckf,
+	Checkuserf: junkReevalCheckf,
+}
+
+// junkReevalConf returns the junk re-evaluation config for the account, and the
+// time from which received messages are re-evaluated.
+func junkReevalConf(acc *store.Account) (conf config.JunkReevaluation, since time.Time, ok bool) {
+	accConf, ok := acc.Conf()
+	if !ok || accConf.JunkReevaluation == nil {
+		return conf, since, false
+	}
+	conf = *accConf.JunkReevaluation
+	window := conf.Window
+	if window == 0 {
+		window = 72 * time.Hour
+	}
+	return conf, time.Now().Add(-window), true
+}
+
+// junkReevalMailboxes returns the Inbox and the mailbox with the Junk special-use
+// role. If the account has no Junk mailbox, ok is false.
+func junkReevalMailboxes(tx *bstore.Tx) (inbox, junk store.Mailbox, ok bool, rerr error) {
+	inbox, err := bstore.QueryTx[store.Mailbox](tx).FilterNonzero(store.Mailbox{Name: "Inbox"}).FilterEqual("Expunged", false).Get()
+	if err == bstore.ErrAbsent {
+		return inbox, junk, false, nil
+	} else if err != nil {
+		return inbox, junk, false, fmt.Errorf("looking up inbox: %v", err)
+	}
+	q := bstore.QueryTx[store.Mailbox](tx)
+	q.FilterEqual("Expunged", false)
+	q.FilterFn(func(mb store.Mailbox) bool {
+		return mb.Junk
+	})
+	junk, err = q.Get()
+	if err == bstore.ErrAbsent {
+		return inbox, junk, false, nil
+	} else if err != nil {
+		return inbox, junk, false, fmt.Errorf("looking up junk mailbox: %v", err)
+	}
+	return inbox, junk, true, nil
+}
+
+// junkReevalMarked is called after a user marked messages as junk or not junk,
+// and re-evaluates messages from the same senders. Errors are logged.
+func junkReevalMarked(ctx context.Context, log mlog.Log, acc *store.Account, marked []store.Message) {
+	type sender struct {
+		localpart smtp.Localpart
+		domain    string
+		toJunk    bool
+	}
+	seen := map[sender]bool{}
+	for _, m := range marked {
+		if m.Junk == m.Notjunk {
+			// Both flags set, not a clear signal.
+			continue
+		}
+		s := sender{m.MsgFromLocalpart, m.MsgFromDomain, m.Junk}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+		_, err := JunkReevaluateSender(ctx, log, acc, m, m.Junk)
+		log.Check(err, "re-evaluating junk status of messages from sender", slog.Int64("msgid", m.ID))
+	}
+}
+
+// JunkReevaluateSender moves recently received messages from the same verified
+// message From address as m to the Junk mailbox if toJunk is set, or from the
+// Junk mailbox to the Inbox otherwise. It is called after the user marked m as
+// junk or not junk. Nothing is done if the account does not have Sender enabled
+// in its JunkReevaluation config, or if the From address of m was not verified.
+func JunkReevaluateSender(ctx context.Context, log mlog.Log, acc *store.Account, m store.Message, toJunk bool) ([]JunkMove, error) {
+	conf, since, ok := junkReevalConf(acc)
+	if !ok || !conf.Sender || !m.MsgFromValidated || m.MsgFromDomain == "" {
+		return nil, nil
+	}
+
+	reason := "sender marked as not junk"
+	if toJunk {
+		reason = "sender marked as junk"
+	}
+
+	var moves []JunkMove
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		inbox, junk, ok, err := junkReevalMailboxes(tx)
+		if err != nil || !ok {
+			return err
+		}
+		src := junk
+		if toJunk {
+			src = inbox
+		}
+
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterNonzero(store.Message{MailboxID: src.ID, MsgFromDomain: m.MsgFromDomain})
+		q.FilterEqual("Expunged", false)
+		q.FilterGreaterEqual("Received", since)
+		q.FilterFn(func(om store.Message) bool {
+			if om.ID == m.ID || !om.MsgFromValidated || om.MsgFromLocalpart != m.MsgFromLocalpart {
+				return false
+			}
+			// Don't move messages out of the Inbox that the user moved there, or marked as
+			// not junk. All messages in the Junk mailbox have the junk flag, they are all
+			// moved back.
+			return !toJunk || om.MailboxOrigID == om.MailboxID && !om.Notjunk
+		})
+		return q.ForEach(func(om store.Message) error {
+			moves = append(moves, junkReevalMove(log, acc, om, toJunk, reason))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return junkReevalApply(ctx, log, acc, conf, moves)
+}
+
+// JunkReevaluateDNSBL checks the remote IPs of recently received messages in the
+// Inbox against the DNSBL zones, and moves messages from listed IPs to the Junk
+// mailbox, unless the junk filter, if configured, classifies them as ham. Nothing
+// is done if the account does not have DNSBL enabled in its JunkReevaluation
+// config. Zones that fail their health check are skipped.
+func JunkReevaluateDNSBL(ctx context.Context, log mlog.Log, resolver dns.Resolver, acc *store.Account, zones []dns.Domain) ([]JunkMove, error) {
+	conf, since, ok := junkReevalConf(acc)
+	if !ok || !conf.DNSBL || len(zones) == 0 {
+		return nil, nil
+	}
+
+	var candidates []store.Message
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		inbox, _, ok, err := junkReevalMailboxes(tx)
+		if err != nil || !ok {
+			return err
+		}
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterNonzero(store.Message{MailboxID: inbox.ID})
+		q.FilterEqual("Expunged", false)
+		q.FilterGreaterEqual("Received", since)
+		q.FilterFn(func(m store.Message) bool {
+			// For forwarded messages, the remote IP is of the forwarding server.
+			return m.RemoteIP != "" && !m.IsForward && m.MailboxOrigID == m.MailboxID && !m.Junk && !m.Notjunk
+		})
+		candidates, err = q.List()
+		return err
+	})
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+
+	var healthy []dns.Domain
+	for _, zone := range zones {
+		if err := dnsbl.CheckZoneHealth(ctx, log.Logger, resolver, zone); err != nil {
+			log.Infox("dnsbl not healthy, skipping for junk re-evaluation", err, slog.Any("zone", zone))
+			continue
+		}
+		healthy = append(healthy, zone)
+	}
+
+	// Remote IP to the zone it is listed in, empty if not listed.
+	listed := map[string]string{}
+	lookup := func(ip string) string {
+		if zone, ok := listed[ip]; ok {
+			return zone
+		}
+		listed[ip] = ""
+		for _, zone := range healthy {
+			status, _, err := dnsbl.LookupCached(ctx, log.Logger, resolver, zone, net.ParseIP(ip))
+			if status == dnsbl.StatusFail {
+				listed[ip] = zone.Name()
+				break
+			} else if err != nil {
+				log.Debugx("dnsbl lookup for junk re-evaluation", err, slog.Any("zone", zone), slog.String("ip", ip))
+			}
+		}
+		return listed[ip]
+	}
+
+	accConf, _ := acc.Conf()
+	jf, _, err := acc.OpenJunkFilter(ctx, log)
+	if err != nil && !errors.Is(err, store.ErrNoJunkFilter) {
+		return nil, fmt.Errorf("open junk filter: %v", err)
+	}
+	defer func() {
+		if jf != nil {
+			err := jf.CloseDiscard()
+			log.Check(err, "closing junk filter")
+		}
+	}()
+
+	var moves []JunkMove
+	for _, m := range candidates {
+		zone := lookup(m.RemoteIP)
+		if zone == "" {
+			continue
+		}
+		reason := fmt.Sprintf("remote ip %s listed in dnsbl %s", m.RemoteIP, zone)
+		if jf != nil {
+			result, err := jf.ClassifyMessageReader(ctx, acc.MessageReader(m), m.Size)
+			if err != nil {
+				log.Errorx("classifying message for junk re-evaluation", err, slog.Int64("msgid", m.ID))
+				continue
+			}
+			if result.Significant && result.Probability < 0.5 {
+				log.Debug("not moving message from dnsbl-listed ip, classified as ham", slog.Int64("msgid", m.ID), slog.Float64("probability", result.Probability))
+				continue
+			}
+			reason += fmt.Sprintf(", junk filter spaminess %.2f (threshold %.2f)", result.Probability, accConf.JunkFilter.Threshold)
+		}
+		moves = append(moves, junkReevalMove(log, acc, m, true, reason))
+	}
+	return junkReevalApply(ctx, log, acc, conf, moves)
+}
+
+// JunkReevaluateSpamtrap moves recently received messages in the Inbox from the
+// remote IP ip, or with a verified message From address in organizational domain
+// orgDomain, to the Junk mailbox, after a message from that sender was sent to a
+// spamtrap. IP is masked like store.Message.RemoteIPMasked1, either ip or
+// orgDomain can be empty. Nothing is done if the account does not have Spamtrap
+// enabled in its JunkReevaluation config.
+func JunkReevaluateSpamtrap(ctx context.Context, log mlog.Log, acc *store.Account, orgDomain, ip string) ([]JunkMove, error) {
+	conf, since, ok := junkReevalConf(acc)
+	if !ok || !conf.Spamtrap || orgDomain == "" && ip == "" {
+		return nil, nil
+	}
+
+	var moves []JunkMove
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		inbox, _, ok, err := junkReevalMailboxes(tx)
+		if err != nil || !ok {
+			return err
+		}
+		q := bstore.QueryTx[store.Message](tx)
+		q.FilterNonzero(store.Message{MailboxID: inbox.ID})
+		q.FilterEqual("Expunged", false)
+		q.FilterGreaterEqual("Received", since)
+		q.FilterFn(func(m store.Message) bool {
+			if m.MailboxOrigID != m.MailboxID || m.Junk || m.Notjunk {
+				return false
+			}
+			// For forwarded messages, the remote IP is of the forwarding server.
+			return ip != "" && !m.IsForward && m.RemoteIPMasked1 == ip || orgDomain != "" && m.MsgFromValidated && m.MsgFromOrgDomain == orgDomain
+		})
+		return q.ForEach(func(m store.Message) error {
+			reason := fmt.Sprintf("sender domain %s sent message to spamtrap", orgDomain)
+			if ip != "" && !m.IsForward && m.RemoteIPMasked1 == ip {
+				reason = fmt.Sprintf("remote ip %s sent message to spamtrap", m.RemoteIP)
+			}
+			moves = append(moves, junkReevalMove(log, acc, m, true, reason))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return junkReevalApply(ctx, log, acc, conf, moves)
+}
+
+// junkReevalMove returns a JunkMove for m, with its From address and subject.
+func junkReevalMove(log mlog.Log, acc *store.Account, m store.Message, toJunk bool, reason string) JunkMove {
+	from := m.MsgFromLocalpart.String() + "@" + m.MsgFromDomain
+	var subject string
+	if p, err := m.LoadPart(acc.MessageReader(m)); err != nil {
+		log.Debugx("loading message part for subject", err, slog.Int64("msgid", m.ID))
+	} else if p.Envelope != nil {
+		subject = p.Envelope.Subject
+	}
+	return JunkMove{m.ID, toJunk, from, subject, reason}
+}
+
+// junkReevalApply moves the messages and delivers a notification to the Inbox if
+// configured. Messages are selected for moving outside of the write transaction,
+// so messages that are no longer in the Inbox or Junk mailbox they are to be moved
+// from, or that were marked as not junk, are skipped. The moves that were applied
+// are returned.
+func junkReevalApply(ctx context.Context, log mlog.Log, acc *store.Account, conf config.JunkReevaluation, moves []JunkMove) (applied []JunkMove, rerr error) {
+	if len(moves) == 0 {
+		return nil, nil
+	}
+
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(junkReevalError); ok {
+			applied = nil
+			rerr = err.err
+			return
+		}
+		panic(x)
+	}()
+
+	acc.WithWLock(func() {
+		var changes []store.Change
+		junkReevalOps.DBWrite(ctx, acc, func(tx *bstore.Tx) {
+			inbox, junk, ok, err := junkReevalMailboxes(tx)
+			junkReevalCheckf(ctx, err, "looking up mailboxes")
+			if !ok {
+				junkReevalCheckf(ctx, errors.New("inbox or junk mailbox not found"), "looking up mailboxes")
+			}
+
+			// Messages may have been moved, expunged or marked by the user since they were
+			// selected.
+			var toJunk, toInbox []int64
+			for _, mv := range moves {
+				m := store.Message{ID: mv.MessageID}
+				err := tx.Get(&m)
+				if err == bstore.ErrAbsent {
+					continue
+				}
+				junkReevalCheckf(ctx, err, "get message")
+				if m.Expunged {
+					continue
+				} else if mv.ToJunk && (m.MailboxID != inbox.ID || m.Notjunk) {
+					continue
+				} else if !mv.ToJunk && m.MailboxID != junk.ID {
+					continue
+				}
+				if mv.ToJunk {
+					toJunk = append(toJunk, mv.MessageID)
+				} else {
+					toInbox = append(toInbox, mv.MessageID)
+				}
+				applied = append(applied, mv)
+			}
+
+			var modseq store.ModSeq
+			if len(toJunk) > 0 {
+				_, l := junkReevalOps.MessageMoveTx(ctx, log, acc, tx, toJunk, junk, &modseq)
+				changes = append(changes, l...)
+			}
+			if len(toInbox) > 0 {
+				// Counts of mailbox were changed by the previous move.
+				inbox, err = store.MailboxID(tx, inbox.ID)
+				junkReevalCheckf(ctx, err, "get inbox")
+				_, l := junkReevalOps.MessageMoveTx(ctx, log, acc, tx, toInbox, inbox, &modseq)
+				changes = append(changes, l...)
+			}
+		})
+		store.BroadcastChanges(acc, changes)
+	})
+	if len(applied) == 0 {
+		return nil, nil
+	}
+
+	for _, mv := range applied {
+		log.Info("moved message after junk re-evaluation",
+			slog.Int64("msgid", mv.MessageID),
+			slog.Bool("tojunk", mv.ToJunk),
+			slog.String("from", mv.From),
+			slog.String("reason", mv.Reason))
+	}
+
+	if conf.Notify {
+		err := junkReevalNotify(log, acc, applied)
+		log.Check(err, "delivering junk re-evaluation notification")
+	}
+	return applied, nil
+}
+
+// junkReevalNotify delivers a message to the Inbox listing the moved messages.
+func junkReevalNotify(log mlog.Log, acc *store.Account, moves []JunkMove) error {
+	f, err := store.CreateMessageTemp(log, "junkreeval")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "message for junk re-evaluation notification")
+
+	// Subjects and addresses come from other messages, we ensure they don't span lines.
+	oneline := func(s string) string {
+		return strings.Join(strings.Fields(s), " ")
+	}
+	var b strings.Builder
+	b.WriteString("Messages were moved after their junk status was re-evaluated.\r\n")
+	for _, mv := range moves {
+		dst := "Inbox"
+		if mv.ToJunk {
+			dst = "Junk"
+		}
+		fmt.Fprintf(&b, "\r\nMoved to %s:\r\n", dst)
+		fmt.Fprintf(&b, "  From: %s\r\n", oneline(mv.From))
+		fmt.Fprintf(&b, "  Subject: %s\r\n", oneline(mv.Subject))
+		fmt.Fprintf(&b, "  Reason: %s\r\n", oneline(mv.Reason))
+	}
+
+	m := store.Message{Received: time.Now()}
+	n, err := fmt.Fprintf(f, "Date: %s\r\nSubject: %d message(s) moved by junk re-evaluation\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8-bit\r\n\r\n%s", time.Now().Format(message.RFC5322Z), len(moves), b.String())
+	if err != nil {
+		return fmt.Errorf("writing temporary message file: %v", err)
+	}
+	m.Size = int64(n)
+
+	acc.WithWLock(func() {
+		err = acc.DeliverMailbox(log, "Inbox", &m, f)
+	})
+	return err
+}
+
+// JunkReevaluateStart starts a goroutine that periodically checks the DNSBLs
+// configured in the SMTP listeners for accounts with DNSBL junk re-evaluation
+// enabled.
+func JunkReevaluateStart(resolver dns.Resolver) {
+	var zones []dns.Domain
+	seen := map[dns.Domain]bool{}
+	for _, l := range mox.Conf.Static.Listeners {
+		for _, zone := range l.SMTP.DNSBLZones {
+			if !seen[zone] {
+				seen[zone] = true
+				zones = append(zones, zone)
+			}
+		}
+	}
+	if len(zones) == 0 {
+		return
+	}
+
+	go func() {
+		log := mlog.New("webops", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Webops)
+			}
+		}()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mox.Shutdown.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, name := range mox.Conf.Accounts() {
+				accConf, ok := mox.Conf.Account(name)
+				if !ok || accConf.JunkReevaluation == nil || !accConf.JunkReevaluation.DNSBL {
+					continue
+				}
+				junkReevalAccountDNSBL(log.WithCid(mox.Cid()), resolver, name, zones)
+			}
+		}
+	}()
+}
+
+func junkReevalAccountDNSBL(log mlog.Log, resolver dns.Resolver, name string, zones []dns.Domain) {
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		log.Errorx("open account for junk re-evaluation", err, slog.String("account", name))
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	ctx, cancel := context.WithTimeout(mox.Shutdown, 10*time.Minute)
+	defer cancel()
+	moves, err := JunkReevaluateDNSBL(ctx, log, resolver, acc, zones)
+	log.Check(err, "junk re-evaluation with dnsbls", slog.String("account", name))
+	if len(moves) > 0 {
+		log.Info("junk re-evaluation with dnsbls moved messages", slog.String("account", name), slog.Int("count", len(moves)))
+	}
+}
+
+// JunkReevaluateSpamtrapHit starts a goroutine that re-evaluates recently
+// received messages in accounts with Spamtrap junk re-evaluation enabled, after a
+// message from the sender was sent to a spamtrap, see JunkReevaluateSpamtrap.
+func JunkReevaluateSpamtrapHit(log mlog.Log, orgDomain, ip string) {
+	var names []string
+	for _, name := range mox.Conf.Accounts() {
+		accConf, ok := mox.Conf.Account(name)
+		if ok && accConf.JunkReevaluation != nil && accConf.JunkReevaluation.Spamtrap {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return
+	}
+
+	go func() {
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Webops)
+			}
+		}()
+
+		for _, name := range names {
+			junkReevalAccountSpamtrap(log, name, orgDomain, ip)
+		}
+	}()
+}
+
+func junkReevalAccountSpamtrap(log mlog.Log, name, orgDomain, ip string) {
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		log.Errorx("open account for junk re-evaluation", err, slog.String("account", name))
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	ctx, cancel := context.WithTimeout(mox.Shutdown, time.Minute)
+	defer cancel()
+	moves, err := JunkReevaluateSpamtrap(ctx, log, acc, orgDomain, ip)
+	log.Check(err, "junk re-evaluation after spamtrap hit", slog.String("account", name))
+	if len(moves) > 0 {
+		log.Info("junk re-evaluation after spamtrap hit moved messages", slog.String("account", name), slog.Int("count", len(moves)))
+	}
+}
@@ -0,0 +1,230 @@
+package webops
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+var pkglog = mlog.New("webops", nil)
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, exp any) {
+	t.Helper()
+	if fmt.Sprint(got) != fmt.Sprint(exp) {
+		t.Fatalf("got %v, expected %v", got, exp)
+	}
+}
+
+func TestJunkReevaluate(t *testing.T) {
+	os.RemoveAll("../testdata/webops/data")
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/webops/mox.conf")
+	mox.ConfigDynamicPath = filepath.FromSlash("../testdata/webops/domains.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		pkglog.Check(err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	checkf := func(ctx context.Context, err error, format string, args ...any) {
+		tcheck(t, err, fmt.Sprintf(format, args...))
+	}
+	xops := XOps{
+		DBWrite: func(ctx context.Context, acc *store.Account, fn func(tx *bstore.Tx)) {
+			err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+				fn(tx)
+				return nil
+			})
+			tcheck(t, err, "db write")
+		},
+		Checkf:     checkf,
+		Checkuserf: checkf,
+	}
+
+	deliver := func(from, remoteIP string, validated bool, flags store.Flags) int64 {
+		t.Helper()
+		lp, dom, _ := strings.Cut(from, "@")
+		msg := fmt.Sprintf("From: <%s>\r\nSubject: test from %s\r\n\r\nhi\r\n", from, lp)
+		m := store.Message{
+			Flags:            flags,
+			RemoteIP:         remoteIP,
+			MsgFromLocalpart: smtp.Localpart(lp),
+			MsgFromDomain:    dom,
+			MsgFromValidated: validated,
+			Size:             int64(len(msg)),
+		}
+		f, err := store.CreateMessageTemp(pkglog, "webops-test")
+		tcheck(t, err, "create temp message")
+		defer store.CloseRemoveTempFile(pkglog, f, "test message")
+		_, err = f.Write([]byte(msg))
+		tcheck(t, err, "write message")
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(pkglog, "Inbox", &m, f)
+		})
+		tcheck(t, err, "deliver message")
+		return m.ID
+	}
+
+	mailboxName := func(id int64) string {
+		t.Helper()
+		var name string
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			m := store.Message{ID: id}
+			if err := tx.Get(&m); err != nil {
+				return err
+			}
+			mb, err := store.MailboxID(tx, m.MailboxID)
+			name = mb.Name
+			return err
+		})
+		tcheck(t, err, "get mailbox of message")
+		return name
+	}
+
+	inboxCount := func() int {
+		t.Helper()
+		var n int
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			inbox, _, _, err := junkReevalMailboxes(tx)
+			if err != nil {
+				return err
+			}
+			n, err = bstore.QueryTx[store.Message](tx).FilterNonzero(store.Message{MailboxID: inbox.ID}).FilterEqual("Expunged", false).Count()
+			return err
+		})
+		tcheck(t, err, "count inbox messages")
+		return n
+	}
+
+	spam1 := deliver("spammer@remote.example", "10.0.0.1", true, store.Flags{})
+	spam2 := deliver("spammer@remote.example", "10.0.0.1", true, store.Flags{})
+	spam3 := deliver("spammer@remote.example", "10.0.0.1", true, store.Flags{Notjunk: true})
+	spoofed := deliver("spammer@remote.example", "10.0.0.2", false, store.Flags{})
+	other := deliver("other@remote.example", "10.0.0.2", true, store.Flags{})
+	tcompare(t, inboxCount(), 5)
+
+	// Marking as junk by moving moves the other message from the verified sender,
+	// but not the message explicitly marked as not junk, or the unverified message.
+	xops.MessageMove(ctxbg, pkglog, acc, []int64{spam1}, "Junk", 0)
+	tcompare(t, mailboxName(spam1), "Junk")
+	tcompare(t, mailboxName(spam2), "Junk")
+	tcompare(t, mailboxName(spam3), "Inbox")
+	tcompare(t, mailboxName(spoofed), "Inbox")
+	tcompare(t, mailboxName(other), "Inbox")
+	// Notification was delivered.
+	tcompare(t, inboxCount(), 3+1)
+
+	// Marking as not junk moves the other message back.
+	xops.MessageFlagsClear(ctxbg, pkglog, acc, []int64{spam1}, []string{"$junk"})
+	xops.MessageFlagsAdd(ctxbg, pkglog, acc, []int64{spam1}, []string{"$notjunk"})
+	tcompare(t, mailboxName(spam1), "Junk")
+	tcompare(t, mailboxName(spam2), "Inbox")
+	tcompare(t, inboxCount(), 4+1+1)
+
+	// Messages from IPs listed in the DNSBL are moved to Junk.
+	resolver := dns.MockResolver{
+		A: map[string][]string{
+			"2.0.0.127.dnsbl.example.": {"127.0.0.2"}, // Required for health.
+			"2.0.0.10.dnsbl.example.":  {"127.0.0.2"},
+		},
+	}
+	zones := []dns.Domain{{ASCII: "dnsbl.example"}}
+	moves, err := JunkReevaluateDNSBL(ctxbg, pkglog, resolver, acc, zones)
+	tcheck(t, err, "junk re-evaluation with dnsbl")
+	tcompare(t, len(moves), 2)
+	tcompare(t, mailboxName(spoofed), "Junk")
+	tcompare(t, mailboxName(other), "Junk")
+	tcompare(t, mailboxName(spam2), "Inbox")
+	tcompare(t, mailboxName(spam3), "Inbox")
+
+	// Unhealthy DNSBLs are not used.
+	spam4 := deliver("new@remote.example", "10.0.0.2", true, store.Flags{})
+	resolver.A = map[string][]string{"2.0.0.10.dnsbl.example.": {"127.0.0.2"}}
+	moves, err = JunkReevaluateDNSBL(ctxbg, pkglog, resolver, acc, zones)
+	tcheck(t, err, "junk re-evaluation with dnsbl")
+	tcompare(t, len(moves), 0)
+	tcompare(t, mailboxName(spam4), "Inbox")
+
+	// Messages outside the window are not re-evaluated.
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		m := store.Message{ID: spam4}
+		if err := tx.Get(&m); err != nil {
+			return err
+		}
+		m.Received = time.Now().Add(-73 * time.Hour)
+		return tx.Update(&m)
+	})
+	tcheck(t, err, "update received time")
+	resolver.A["2.0.0.127.dnsbl.example."] = []string{"127.0.0.2"}
+	moves, err = JunkReevaluateDNSBL(ctxbg, pkglog, resolver, acc, zones)
+	tcheck(t, err, "junk re-evaluation with dnsbl")
+	tcompare(t, len(moves), 0)
+
+	// Messages from the IP or verified domain of a sender that sent to a spamtrap are
+	// moved to Junk.
+	trap1 := deliver("trapped@trap.example", "10.0.0.3", true, store.Flags{})
+	trap2 := deliver("spoofed@trap.example", "10.0.0.4", false, store.Flags{})
+	trap3 := deliver("other@elsewhere.example", "10.0.0.3", false, store.Flags{})
+	trap4 := deliver("trapped@trap.example", "10.0.0.3", true, store.Flags{Notjunk: true})
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		for _, id := range []int64{trap1, trap2, trap3, trap4} {
+			m := store.Message{ID: id}
+			if err := tx.Get(&m); err != nil {
+				return err
+			}
+			m.RemoteIPMasked1 = m.RemoteIP
+			m.MsgFromOrgDomain = m.MsgFromDomain
+			if err := tx.Update(&m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	tcheck(t, err, "update messages")
+	moves, err = JunkReevaluateSpamtrap(ctxbg, pkglog, acc, "trap.example", "10.0.0.3")
+	tcheck(t, err, "junk re-evaluation after spamtrap hit")
+	tcompare(t, len(moves), 2)
+	tcompare(t, mailboxName(trap1), "Junk")
+	tcompare(t, mailboxName(trap2), "Inbox")
+	tcompare(t, mailboxName(trap3), "Junk")
+	tcompare(t, mailboxName(trap4), "Inbox")
+
+	// Messages no longer in the mailbox they were selected in are not moved.
+	moves, err = junkReevalApply(ctxbg, pkglog, acc, config.JunkReevaluation{}, []JunkMove{{MessageID: trap1, ToJunk: true}, {MessageID: trap2, ToJunk: false}})
+	tcheck(t, err, "apply junk re-evaluation")
+	tcompare(t, len(moves), 0)
+	tcompare(t, mailboxName(trap1), "Junk")
+	tcompare(t, mailboxName(trap2), "Inbox")
+}
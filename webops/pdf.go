@@ -0,0 +1,349 @@
+package webops
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/store"
+)
+
+// Page layout for PDF exports, in points. A4 paper, with a monospace font so we
+// can wrap lines without font metrics.
+const (
+	pdfPageWidth  = 595
+	pdfPageHeight = 842
+	pdfMargin     = 50
+	pdfFontSize   = 9
+	pdfLeading    = 11
+	pdfLineChars  = (pdfPageWidth - 2*pdfMargin) * 10 / (pdfFontSize * 6) // Courier is 0.6em wide.
+	pdfPageLines  = (pdfPageHeight - 2*pdfMargin - 2*pdfLeading) / pdfLeading
+)
+
+// Text of a message body included in a PDF export is limited to this size.
+const pdfMaxBodyText = 256 * 1024
+
+// ExportPDF writes the messages as a single PDF document to w, in order of
+// receive time. Each message starts on a new page with its main header fields,
+// followed by the text of the message, and a list of the attachments, including
+// inline attachments. Attachment contents are not included.
+//
+// The PDF is built in memory and only written to w when complete, so nothing
+// has been written when an error other than a write error is returned.
+func ExportPDF(ctx context.Context, log mlog.Log, acc *store.Account, w io.Writer, messageIDs []int64) error {
+	var msgs []store.Message
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		for _, id := range messageIDs {
+			m := store.Message{ID: id}
+			if err := tx.Get(&m); err != nil {
+				return fmt.Errorf("get message %d: %w", id, err)
+			} else if m.Expunged {
+				return fmt.Errorf("message %d was removed", id)
+			}
+			msgs = append(msgs, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	slices.SortStableFunc(msgs, func(a, b store.Message) int {
+		return a.Received.Compare(b.Received)
+	})
+
+	var pages [][]pdfLine
+	for _, m := range msgs {
+		lines, err := pdfMessageLines(log, acc, m)
+		if err != nil {
+			return fmt.Errorf("message %d: %w", m.ID, err)
+		}
+		for len(lines) > 0 {
+			n := min(len(lines), pdfPageLines)
+			pages = append(pages, lines[:n])
+			lines = lines[n:]
+		}
+	}
+	if len(pages) == 0 {
+		return errors.New("no messages")
+	}
+
+	return pdfWrite(w, pages)
+}
+
+type pdfLine struct {
+	Bold bool
+	Text string
+}
+
+// pdfMessageLines returns the wrapped lines for a message.
+func pdfMessageLines(log mlog.Log, acc *store.Account, m store.Message) ([]pdfLine, error) {
+	mr := acc.MessageReader(m)
+	defer func() {
+		err := mr.Close()
+		log.Check(err, "closing message reader")
+	}()
+	p, err := m.LoadPart(mr)
+	if err != nil {
+		return nil, fmt.Errorf("load parsed message: %w", err)
+	}
+
+	var lines []pdfLine
+	add := func(bold bool, s string) {
+		for _, l := range pdfWrap(s) {
+			lines = append(lines, pdfLine{bold, l})
+		}
+	}
+	field := func(k, v string) {
+		if v != "" {
+			add(true, k+": "+v)
+		}
+	}
+
+	env := p.Envelope
+	if env == nil {
+		env = &message.Envelope{}
+	}
+	date := env.Date
+	if date.IsZero() {
+		date = m.Received
+	}
+	field("Date", date.Format(time.RFC1123Z))
+	field("From", pdfAddresses(env.From))
+	field("To", pdfAddresses(env.To))
+	field("Cc", pdfAddresses(env.CC))
+	field("Subject", env.Subject)
+	add(false, "")
+
+	var body *message.Part
+	var attachments []*message.Part
+	pdfMessageParts(log, &p, &body, &attachments)
+
+	if body != nil {
+		buf, err := io.ReadAll(&moxio.LimitReader{R: body.ReaderUTF8OrBinary(), Limit: pdfMaxBodyText})
+		if err != nil && !errors.Is(err, moxio.ErrLimit) {
+			log.Debugx("reading message text for pdf export, continuing", err, slog.Int64("msgid", m.ID))
+		}
+		text := string(buf)
+		if body.MediaSubType == "HTML" {
+			text, err = message.HTMLText(bytes.NewReader(buf), pdfMaxBodyText)
+			if err != nil {
+				log.Debugx("parsing html for pdf export, continuing", err, slog.Int64("msgid", m.ID))
+			}
+		}
+		text = strings.ReplaceAll(text, "\r\n", "\n")
+		for _, l := range strings.Split(strings.TrimRight(text, "\n"), "\n") {
+			add(false, l)
+		}
+	}
+
+	if len(attachments) > 0 {
+		add(false, "")
+		add(true, "Attachments:")
+		for _, a := range attachments {
+			_, name, err := a.DispositionFilename()
+			if err != nil {
+				log.Debugx("parsing disposition header for filename", err)
+			}
+			if name == "" {
+				name = "(unnamed)"
+			}
+			mt := strings.ToLower(a.MediaType + "/" + a.MediaSubType)
+			add(false, fmt.Sprintf("- %s (%s, %d bytes)", name, mt, a.DecodedSize))
+		}
+	}
+	return lines, nil
+}
+
+// pdfMessageParts finds the part with the message text, preferring text/plain in
+// multipart/alternative, and all other leaf parts as attachments.
+func pdfMessageParts(log mlog.Log, p *message.Part, body **message.Part, attachments *[]*message.Part) {
+	if len(p.Parts) == 0 {
+		disp, _, err := p.DispositionFilename()
+		if err != nil {
+			log.Debugx("parsing disposition header", err)
+		}
+		mt := p.MediaType + "/" + p.MediaSubType
+		if *body == nil && (mt == "TEXT/PLAIN" || mt == "TEXT/HTML" || mt == "/") && !strings.EqualFold(disp, "attachment") {
+			*body = p
+		} else {
+			*attachments = append(*attachments, p)
+		}
+		return
+	}
+
+	if p.MediaType == "MULTIPART" && p.MediaSubType == "ALTERNATIVE" {
+		alt := &p.Parts[0]
+		for i := range p.Parts {
+			sp := &p.Parts[i]
+			if sp.MediaType == "TEXT" && sp.MediaSubType == "PLAIN" {
+				alt = sp
+				break
+			} else if sp.MediaType == "TEXT" && sp.MediaSubType == "HTML" && alt.MediaType != "TEXT" {
+				alt = sp
+			}
+		}
+		pdfMessageParts(log, alt, body, attachments)
+		return
+	}
+
+	for i := range p.Parts {
+		pdfMessageParts(log, &p.Parts[i], body, attachments)
+	}
+}
+
+func pdfAddresses(l []message.Address) string {
+	var r []string
+	for _, a := range l {
+		s := a.User + "@" + a.Host
+		if a.Name != "" {
+			s = a.Name + " <" + s + ">"
+		}
+		r = append(r, s)
+	}
+	return strings.Join(r, ", ")
+}
+
+// pdfWrap returns s wrapped into lines of at most pdfLineChars characters,
+// preferring to break at a space.
+func pdfWrap(s string) []string {
+	// Expand tabs, the PDF text has no tab stops.
+	if strings.Contains(s, "\t") {
+		var b strings.Builder
+		n := 0
+		for _, c := range s {
+			if c == '\t' {
+				k := 8 - n%8
+				b.WriteString(strings.Repeat(" ", k))
+				n += k
+			} else {
+				b.WriteRune(c)
+				n++
+			}
+		}
+		s = b.String()
+	}
+	s = strings.TrimRight(s, " \r")
+
+	var l []string
+	for utf8.RuneCountInString(s) > pdfLineChars {
+		r := []rune(s)
+		n := pdfLineChars
+		if i := strings.LastIndex(string(r[:n]), " "); i > 0 {
+			n = utf8.RuneCountInString(s[:i]) + 1
+		}
+		l = append(l, strings.TrimRight(string(r[:n]), " "))
+		s = string(r[n:])
+	}
+	return append(l, s)
+}
+
+// pdfWinAnsi maps characters in the 0x80-0x9f range of WinAnsiEncoding. Other
+// characters below 0x100 map directly.
+var pdfWinAnsi = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87, 'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97, '˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// pdfString returns s as PDF string literal in WinAnsiEncoding. Characters that
+// cannot be represented are replaced with a question mark.
+func pdfString(s string) string {
+	b := []byte{'('}
+	for _, c := range s {
+		var x byte
+		if ax, ok := pdfWinAnsi[c]; ok {
+			x = ax
+		} else if c >= 0x20 && c < 0x7f || c >= 0xa0 && c < 0x100 {
+			x = byte(c)
+		} else {
+			x = '?'
+		}
+		if x == '(' || x == ')' || x == '\\' {
+			b = append(b, '\\')
+		}
+		b = append(b, x)
+	}
+	return string(append(b, ')'))
+}
+
+// pdfWrite writes a PDF document with the pages of text lines, with a page
+// number at the bottom of each page.
+func pdfWrite(w io.Writer, pages [][]pdfLine) error {
+	var buf bytes.Buffer
+	var offsets []int
+	obj := func(format string, args ...any) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n", len(offsets))
+		fmt.Fprintf(&buf, format, args...)
+		buf.WriteString("\nendobj\n")
+	}
+	stream := func(data []byte) error {
+		var zbuf bytes.Buffer
+		zw := zlib.NewWriter(&zbuf)
+		if _, err := zw.Write(data); err != nil {
+			return err
+		}
+		if err := zw.Close(); err != nil {
+			return err
+		}
+		obj("<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream", zbuf.Len(), zbuf.Bytes())
+		return nil
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1-4 are fixed, followed by a page and its content stream for each page.
+	var kids []string
+	for i := range pages {
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+	}
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, lines := range pages {
+		obj("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pdfPageWidth, pdfPageHeight, 5+2*i+1)
+
+		var c bytes.Buffer
+		fmt.Fprintf(&c, "BT\n%d TL\n%d %d Td\n", pdfLeading, pdfMargin, pdfPageHeight-pdfMargin-pdfFontSize)
+		font := ""
+		for _, l := range lines {
+			f := "/F1"
+			if l.Bold {
+				f = "/F2"
+			}
+			if f != font {
+				fmt.Fprintf(&c, "%s %d Tf\n", f, pdfFontSize)
+				font = f
+			}
+			fmt.Fprintf(&c, "%s Tj T*\n", pdfString(l.Text))
+		}
+		fmt.Fprintf(&c, "ET\nBT\n/F1 %d Tf\n%d %d Td\n%s Tj\nET\n", pdfFontSize, pdfMargin, pdfMargin-pdfLeading, pdfString(fmt.Sprintf("Page %d of %d", i+1, len(pages))))
+		if err := stream(c.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, o := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", o)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,102 @@
+package webops
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+// QuotaWarnStart starts a goroutine that periodically checks the storage use of
+// accounts with a quota, delivering a warning message to the Inbox when a
+// percentage of QuotaWarnPercentages is reached.
+func QuotaWarnStart() {
+	go func() {
+		log := mlog.New("webops", nil)
+
+		defer func() {
+			// In case of panic don't take the whole program down.
+			x := recover()
+			if x != nil {
+				log.Error("recovered from panic", slog.Any("panic", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Webops)
+			}
+		}()
+
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-mox.Shutdown.Done():
+				return
+			case <-ticker.C:
+			}
+
+			for _, name := range mox.Conf.Accounts() {
+				quotaWarnAccount(log.WithCid(mox.Cid()), name)
+			}
+		}
+	}()
+}
+
+func quotaWarnAccount(log mlog.Log, name string) {
+	acc, err := store.OpenAccount(log, name, false)
+	if err != nil {
+		log.Errorx("open account for quota check", err, slog.String("account", name))
+		return
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	err = QuotaWarn(log, acc)
+	log.Check(err, "checking quota", slog.String("account", name))
+}
+
+// QuotaWarn delivers a warning message to the Inbox of the account if its storage
+// use reached a higher percentage of QuotaWarnPercentages than at the previous
+// warning.
+func QuotaWarn(log mlog.Log, acc *store.Account) error {
+	qs, warn, err := acc.QuotaWarnCheck(mox.Shutdown)
+	if err != nil {
+		return err
+	} else if warn == 0 {
+		return nil
+	}
+
+	f, err := store.CreateMessageTemp(log, "quotawarn")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "message for quota warning")
+
+	text := fmt.Sprintf("Your account is using %d of %d bytes of storage (%d%%).\r\n\r\n", qs.MessageSize, qs.Quota, qs.Percent)
+	if qs.SoftLimit > 0 {
+		text += fmt.Sprintf("Over the soft limit of %d bytes, new messages are only accepted during a grace period of %s.\r\n\r\n", qs.SoftLimit, store.QuotaGracePeriod())
+	}
+	text += "New messages are rejected when the account is over its quota. Remove messages, e.g. large messages or messages in the Trash and Junk mailboxes, to make room.\r\n"
+
+	m := store.Message{Received: time.Now(), Flags: store.Flags{Flagged: true}}
+	n, err := fmt.Fprintf(f, "Date: %s\r\nSubject: Storage at %d%% of quota\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8-bit\r\n\r\n%s", time.Now().Format(message.RFC5322Z), warn, text)
+	if err != nil {
+		return fmt.Errorf("writing temporary message file: %v", err)
+	}
+	m.Size = int64(n)
+
+	acc.WithWLock(func() {
+		err = acc.DeliverMailbox(log, "Inbox", &m, f)
+	})
+	if err != nil {
+		return fmt.Errorf("delivering quota warning: %w", err)
+	}
+	log.Info("delivered quota warning", slog.String("account", acc.Name), slog.Int("percent", qs.Percent))
+	return nil
+}
@@ -0,0 +1,156 @@
+package webops
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+func TestQuota(t *testing.T) {
+	os.RemoveAll("../testdata/webops/data")
+	mox.Context = ctxbg
+	mox.Shutdown = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/webops/mox.conf")
+	mox.ConfigDynamicPath = filepath.FromSlash("../testdata/webops/domains.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		pkglog.Check(err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	// Quota from the domain of the account.
+	conf, _ := acc.Conf()
+	dom, _ := mox.Conf.Domain(conf.DNSDomain)
+	dom.QuotaMessageSize = 10000
+	mox.Conf.Dynamic.Domains["mox.example"] = dom
+	mox.Conf.Static.QuotaSoftPercent = 50
+	mox.Conf.Static.QuotaGracePeriod = time.Hour
+	mox.Conf.Static.QuotaWarnPercentages = []int{50}
+	defer func() {
+		dom.QuotaMessageSize = 0
+		mox.Conf.Dynamic.Domains["mox.example"] = dom
+		mox.Conf.Static.QuotaSoftPercent = 0
+		mox.Conf.Static.QuotaGracePeriod = 0
+		mox.Conf.Static.QuotaWarnPercentages = nil
+	}()
+	tcompare(t, acc.QuotaMessageSize(), 10000)
+	tcompare(t, acc.QuotaSoftLimit(), 5000)
+
+	deliver := func(size int) error {
+		t.Helper()
+		msg := "Subject: test\r\n\r\n" + strings.Repeat("x", size-len("Subject: test\r\n\r\n"))
+		m := store.Message{Received: time.Now(), Size: int64(len(msg))}
+		f, err := store.CreateMessageTemp(pkglog, "quotatest")
+		tcheck(t, err, "temp file")
+		defer store.CloseRemoveTempFile(pkglog, f, "test message")
+		_, err = f.WriteString(msg)
+		tcheck(t, err, "write message")
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(pkglog, "Inbox", &m, f)
+		})
+		return err
+	}
+
+	status := func() (qs store.QuotaStatus) {
+		t.Helper()
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			var err error
+			qs, err = acc.QuotaStatus(tx)
+			return err
+		})
+		tcheck(t, err, "quota status")
+		return
+	}
+
+	canAdd := func(size int64) bool {
+		t.Helper()
+		var ok bool
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			var err error
+			ok, _, err = acc.CanAddMessageSize(tx, size)
+			return err
+		})
+		tcheck(t, err, "can add message size")
+		return ok
+	}
+
+	// Below soft limit, no warning.
+	err = deliver(1000)
+	tcheck(t, err, "deliver")
+	qs := status()
+	tcompare(t, qs.Percent, 10)
+	tcompare(t, qs.Warning(), "")
+	err = QuotaWarn(pkglog, acc)
+	tcheck(t, err, "quota warn")
+	tcompare(t, status().MessageSize, 1000)
+
+	// Over soft limit, the grace period starts and a warning is delivered once.
+	err = deliver(5000)
+	tcheck(t, err, "deliver")
+	qs = status()
+	if qs.GraceEnd.IsZero() || !strings.Contains(qs.Warning(), "soft limit") {
+		t.Fatalf("expected grace period and warning, got %#v, %q", qs, qs.Warning())
+	}
+	err = QuotaWarn(pkglog, acc)
+	tcheck(t, err, "quota warn")
+	size := status().MessageSize
+	if size <= 6000 {
+		t.Fatalf("expected warning message delivered, size %d", size)
+	}
+	err = QuotaWarn(pkglog, acc)
+	tcheck(t, err, "quota warn")
+	tcompare(t, status().MessageSize, size)
+
+	// Still allowed during grace period, but not over the hard limit.
+	tcompare(t, canAdd(100), true)
+	tcompare(t, canAdd(5000), false)
+	err = deliver(5000)
+	if err == nil || !strings.Contains(err.Error(), store.ErrOverQuota.Error()) {
+		t.Fatalf("got err %v, expected over quota", err)
+	}
+
+	// After the grace period, nothing can be added until below soft limit.
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		du := store.DiskUsage{ID: 1}
+		if err := tx.Get(&du); err != nil {
+			return err
+		}
+		du.SoftLimitExceeded = time.Now().Add(-2 * time.Hour)
+		return tx.Update(&du)
+	})
+	tcheck(t, err, "update disk usage")
+	tcompare(t, canAdd(100), false)
+	if !strings.Contains(status().Warning(), "rejected until") {
+		t.Fatalf("expected warning about rejection, got %q", status().Warning())
+	}
+	err = deliver(100)
+	if err == nil || !strings.Contains(err.Error(), "grace period") {
+		t.Fatalf("got err %v, expected grace period ended", err)
+	}
+
+	// Account quota overrides domain quota.
+	conf.QuotaMessageSize = -1
+	mox.Conf.Dynamic.Accounts["mjl"] = conf
+	tcompare(t, acc.QuotaMessageSize(), 0)
+	tcompare(t, canAdd(100), true)
+	conf.QuotaMessageSize = 0
+	mox.Conf.Dynamic.Accounts["mjl"] = conf
+}
@@ -0,0 +1,232 @@
+package webops
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/store"
+)
+
+// RuleForward is a message to forward because of a matching rule. Forwarding is
+// done by the caller, messages are not forwarded when running rules on existing
+// messages.
+type RuleForward struct {
+	MessageID int64
+	To        []string
+}
+
+// Rules are applied outside of API requests. Errors while changing messages are
+// raised as rulesError panics, and recovered.
+type rulesError struct {
+	err error
+}
+
+func rulesCheckf(ctx context.Context, err error, format string, args ...any) {
+	if err != nil {
+		panic(rulesError{fmt.Errorf("%s: %w", fmt.Sprintf(format, args...), err)})
+	}
+}
+
+var rulesOps = XOps{
+	DBWrite: func(ctx context.Context, acc *store.Account, fn func(tx *bstore.Tx)) {
+		err := acc.DB.Write(ctx, func(tx *bstore.Tx) error {
+			fn(tx)
+			return nil
+		})
+		rulesCheckf(ctx, err, "transaction")
+	},
+	Checkf:     rulesCheckf,
+	Checkuserf: rulesCheckf,
+}
+
+// RuleMatch returns whether the message with parsed part p matches the conditions
+// of rule r.
+func RuleMatch(log mlog.Log, r store.Rule, p *message.Part) bool {
+	contains := func(s, substr string) bool {
+		return strings.Contains(strings.ToLower(s), strings.ToLower(substr))
+	}
+	addrsContain := func(l []message.Address, substr string) bool {
+		for _, a := range l {
+			if contains(a.Name, substr) || contains(a.User+"@"+a.Host, substr) {
+				return true
+			}
+		}
+		return false
+	}
+
+	env := p.Envelope
+	if env == nil {
+		env = &message.Envelope{}
+	}
+	if r.From != "" && !addrsContain(env.From, r.From) {
+		return false
+	}
+	if r.To != "" && !addrsContain(env.To, r.To) && !addrsContain(env.CC, r.To) {
+		return false
+	}
+	if r.SubjectContains != "" && !contains(env.Subject, r.SubjectContains) {
+		return false
+	}
+	if r.ListID != "" {
+		h, err := p.Header()
+		if err != nil {
+			log.Debugx("parsing message header for list-id", err)
+			return false
+		}
+		if !contains(h.Get("List-Id"), r.ListID) {
+			return false
+		}
+	}
+	if r.HasAttachment {
+		var body *message.Part
+		var attachments []*message.Part
+		pdfMessageParts(log, p, &body, &attachments)
+		if len(attachments) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// RulesApply evaluates the enabled rules of the account against the messages, in
+// order of position, and moves and marks messages as read as requested by the
+// matching rules. If forward is set, forwards requested by matching rules are
+// returned for the caller to send. The number of messages that matched a rule is
+// returned.
+func RulesApply(ctx context.Context, log mlog.Log, acc *store.Account, messageIDs []int64, forward bool) (matched int, forwards []RuleForward, rerr error) {
+	defer func() {
+		x := recover()
+		if x == nil {
+			return
+		}
+		if err, ok := x.(rulesError); ok {
+			rerr = err.err
+			return
+		}
+		panic(x)
+	}()
+
+	// Destination mailbox to message IDs.
+	moves := map[int64][]int64{}
+	var markRead []int64
+
+	err := acc.DB.Read(ctx, func(tx *bstore.Tx) error {
+		rules, err := bstore.QueryTx[store.Rule](tx).FilterEqual("Disabled", false).SortAsc("Position", "ID").List()
+		if err != nil || len(rules) == 0 {
+			return err
+		}
+
+		// Mailbox names of rules to IDs, 0 if the mailbox does not exist.
+		mailboxIDs := map[string]int64{}
+		for _, r := range rules {
+			if r.Mailbox == "" {
+				continue
+			}
+			if _, ok := mailboxIDs[r.Mailbox]; ok {
+				continue
+			}
+			mb, err := acc.MailboxFind(tx, r.Mailbox)
+			if err != nil {
+				return fmt.Errorf("looking up mailbox for rule: %v", err)
+			} else if mb == nil {
+				log.Info("mailbox for rule not found, not moving messages", slog.String("rule", r.Name), slog.String("mailbox", r.Mailbox))
+				mailboxIDs[r.Mailbox] = 0
+			} else {
+				mailboxIDs[r.Mailbox] = mb.ID
+			}
+		}
+
+		var trashID int64
+		q := bstore.QueryTx[store.Mailbox](tx)
+		q.FilterEqual("Expunged", false)
+		q.FilterFn(func(mb store.Mailbox) bool {
+			return mb.Trash
+		})
+		if trash, err := q.Get(); err == nil {
+			trashID = trash.ID
+		} else if err != bstore.ErrAbsent {
+			return fmt.Errorf("looking up trash mailbox: %v", err)
+		}
+
+		for _, id := range messageIDs {
+			m, err := bstore.QueryTx[store.Message](tx).FilterID(id).FilterEqual("Expunged", false).Get()
+			if err == bstore.ErrAbsent {
+				continue
+			} else if err != nil {
+				return fmt.Errorf("get message: %v", err)
+			}
+			p, err := m.LoadPart(acc.MessageReader(m))
+			if err != nil {
+				log.Errorx("loading message part for rules", err, slog.Int64("msgid", m.ID))
+				continue
+			}
+
+			var match, read bool
+			var mailboxID int64
+			var to []string
+			for _, r := range rules {
+				if !RuleMatch(log, r, &p) {
+					continue
+				}
+				match = true
+				if r.Mailbox != "" && mailboxIDs[r.Mailbox] != 0 {
+					mailboxID = mailboxIDs[r.Mailbox]
+				}
+				if r.Delete {
+					if trashID == 0 {
+						log.Info("no trash mailbox, not deleting message for rule", slog.String("rule", r.Name), slog.Int64("msgid", m.ID))
+					} else {
+						mailboxID = trashID
+					}
+				}
+				read = read || r.MarkRead
+				for _, addr := range r.ForwardTo {
+					if !slices.Contains(to, addr) {
+						to = append(to, addr)
+					}
+				}
+				if r.Stop {
+					break
+				}
+			}
+			if !match {
+				continue
+			}
+			matched++
+			if mailboxID != 0 && mailboxID != m.MailboxID {
+				moves[mailboxID] = append(moves[mailboxID], m.ID)
+			}
+			if read && !m.Seen {
+				markRead = append(markRead, m.ID)
+			}
+			if forward && len(to) > 0 {
+				forwards = append(forwards, RuleForward{m.ID, to})
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// Flags are set before moving, they are kept by the move.
+	if len(markRead) > 0 {
+		rulesOps.MessageFlagsAdd(ctx, log, acc, markRead, []string{`\seen`})
+	}
+	dstIDs := make([]int64, 0, len(moves))
+	for id := range moves {
+		dstIDs = append(dstIDs, id)
+	}
+	slices.Sort(dstIDs)
+	for _, id := range dstIDs {
+		rulesOps.MessageMove(ctx, log, acc, moves[id], "", id)
+	}
+	return matched, forwards, nil
+}
@@ -0,0 +1,114 @@
+package webops
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+func TestRules(t *testing.T) {
+	os.RemoveAll("../testdata/webops/data")
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/webops/mox.conf")
+	mox.ConfigDynamicPath = filepath.FromSlash("../testdata/webops/domains.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		pkglog.Check(err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	deliver := func(msg string) int64 {
+		t.Helper()
+		m := store.Message{Size: int64(len(msg))}
+		f, err := store.CreateMessageTemp(pkglog, "webops-test")
+		tcheck(t, err, "create temp message")
+		defer store.CloseRemoveTempFile(pkglog, f, "test message")
+		_, err = f.Write([]byte(msg))
+		tcheck(t, err, "write message")
+		acc.WithWLock(func() {
+			err = acc.DeliverMailbox(pkglog, "Inbox", &m, f)
+		})
+		tcheck(t, err, "deliver message")
+		return m.ID
+	}
+
+	message := func(id int64) (store.Message, string) {
+		t.Helper()
+		m := store.Message{ID: id}
+		var name string
+		err := acc.DB.Read(ctxbg, func(tx *bstore.Tx) error {
+			if err := tx.Get(&m); err != nil {
+				return err
+			}
+			mb, err := store.MailboxID(tx, m.MailboxID)
+			name = mb.Name
+			return err
+		})
+		tcheck(t, err, "get message")
+		return m, name
+	}
+
+	list := deliver("From: <list@lists.example>\r\nTo: <mjl@mox.example>\r\nSubject: news\r\nList-Id: Weekly News <news.lists.example>\r\n\r\nhi\r\n")
+	invoice := deliver("From: Billing <billing@shop.example>\r\nTo: <other@mox.example>\r\nCc: <mjl@mox.example>\r\nSubject: Your Invoice\r\nMIME-Version: 1.0\r\nContent-Type: multipart/mixed; boundary=x\r\n\r\n--x\r\nContent-Type: text/plain\r\n\r\nsee attached\r\n--x\r\nContent-Type: application/pdf\r\nContent-Disposition: attachment; filename=invoice.pdf\r\n\r\n%PDF\r\n--x--\r\n")
+	invoiceNoAttachment := deliver("From: <billing@shop.example>\r\nTo: <mjl@mox.example>\r\nSubject: invoice reminder\r\n\r\nhi\r\n")
+	spam := deliver("From: <spammer@spam.example>\r\nTo: <mjl@mox.example>\r\nSubject: offer\r\n\r\nhi\r\n")
+	other := deliver("From: <friend@remote.example>\r\nTo: <mjl@mox.example>\r\nSubject: hello\r\n\r\nhi\r\n")
+
+	rules := []store.Rule{
+		{Position: 1, Name: "news", ListID: "NEWS.lists.example", Mailbox: "Archive", MarkRead: true, Stop: true},
+		{Position: 2, Name: "invoices", From: "billing@", SubjectContains: "invoice", To: "mjl@mox.example", HasAttachment: true, Mailbox: "Archive", ForwardTo: []string{"accounting@remote.example"}},
+		{Position: 3, Name: "spam", From: "spam.example", Delete: true},
+		{Position: 4, Name: "disabled", Disabled: true, From: "friend", MarkRead: true},
+		// Not reached for the list message, because of Stop in the first rule.
+		{Position: 5, Name: "lists", From: "lists.example", Delete: true},
+	}
+	for i := range rules {
+		err := acc.DB.Insert(ctxbg, &rules[i])
+		tcheck(t, err, "insert rule")
+	}
+
+	ids := []int64{list, invoice, invoiceNoAttachment, spam, other}
+	matched, forwards, err := RulesApply(ctxbg, pkglog, acc, ids, true)
+	tcheck(t, err, "apply rules")
+	tcompare(t, matched, 3)
+	tcompare(t, forwards, []RuleForward{{invoice, []string{"accounting@remote.example"}}})
+
+	m, mbname := message(list)
+	tcompare(t, mbname, "Archive")
+	tcompare(t, m.Seen, true)
+	m, mbname = message(invoice)
+	tcompare(t, mbname, "Archive")
+	tcompare(t, m.Seen, false)
+	_, mbname = message(invoiceNoAttachment)
+	tcompare(t, mbname, "Inbox")
+	_, mbname = message(spam)
+	tcompare(t, mbname, "Trash")
+	m, mbname = message(other)
+	tcompare(t, mbname, "Inbox")
+	tcompare(t, m.Seen, false)
+
+	// Running again doesn't move messages already in their destination, and without
+	// forward, no forwards are returned.
+	matched, forwards, err = RulesApply(ctxbg, pkglog, acc, ids, false)
+	tcheck(t, err, "apply rules")
+	tcompare(t, matched, 3)
+	tcompare(t, len(forwards), 0)
+	_, mbname = message(spam)
+	tcompare(t, mbname, "Trash")
+}
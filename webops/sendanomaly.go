@@ -0,0 +1,145 @@
+package webops
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjl-/mox/config"
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/store"
+)
+
+var (
+	ErrSendSuspended = errors.New("sending suspended for account after anomaly in outgoing messages, ask the admin to resume sending")
+	ErrSendThrottled = errors.New("sending throttled for account after anomaly in outgoing messages, try again later")
+)
+
+// Alerts about anomalies are sent at most once per period, per account and kind
+// of anomaly.
+const sendAnomalyAlertPeriod = 24 * time.Hour
+
+var sendAnomalyAlerts = struct {
+	sync.Mutex
+	last map[string]time.Time // Key is account and anomaly kind.
+}{last: map[string]time.Time{}}
+
+// SendAnomalies checks a submission by acc to recipients for anomalies, as
+// configured in OutgoingAnomalies, alerting the postmaster about new anomalies.
+// RemoteIP must only be set for SMTP submissions.
+//
+// If sending is suspended for the account, ErrSendSuspended is returned. If the
+// configured action is to throttle and an anomaly blocks sending,
+// ErrSendThrottled is returned.
+//
+// Accounts exempted from rate limits in the configuration are not checked.
+func SendAnomalies(ctx context.Context, log mlog.Log, acc *store.Account, recipients []smtp.Path, remoteIP net.IP) error {
+	if e := mox.Exempted(nil, acc.Name); e.RateLimits {
+		log.Debug("account exempted from outgoing anomaly checks", slog.String("account", acc.Name), slog.Any("comments", e.Comments))
+		return nil
+	}
+
+	anomalies, suspension, err := acc.SendAnomalyCheck(ctx, recipients, remoteIP)
+	if err != nil {
+		return fmt.Errorf("checking for anomalies in outgoing messages: %v", err)
+	}
+
+	conf := mox.Conf.Static.OutgoingAnomalies
+	var block bool
+	for _, an := range anomalies {
+		log.Info("anomaly in outgoing message", slog.String("account", acc.Name), slog.String("kind", an.Kind), slog.String("text", an.Text))
+		block = block || an.Block
+	}
+	if len(anomalies) > 0 {
+		var action string
+		if suspension != nil {
+			action = "Sending has been suspended for the account. Resume sending in the admin web interface after investigating."
+		} else if block && (conf.Action == "" || conf.Action == config.OutgoingAnomalyThrottle) {
+			action = "Submissions are rejected with a temporary error while the anomaly persists."
+		}
+		sendAnomalyAlert(log, acc.Name, anomalies, action)
+	}
+
+	if suspension != nil {
+		return ErrSendSuspended
+	} else if block && (conf.Action == "" || conf.Action == config.OutgoingAnomalyThrottle) {
+		return ErrSendThrottled
+	}
+	return nil
+}
+
+// sendAnomalyAlert delivers a message about anomalies to the postmaster account,
+// for kinds of anomalies not alerted about recently for the account.
+func sendAnomalyAlert(log mlog.Log, accountName string, anomalies []store.SendAnomaly, action string) {
+	now := time.Now()
+	var l []store.SendAnomaly
+	sendAnomalyAlerts.Lock()
+	for _, an := range anomalies {
+		k := accountName + "\x00" + an.Kind
+		if t, ok := sendAnomalyAlerts.last[k]; ok && now.Sub(t) < sendAnomalyAlertPeriod {
+			continue
+		}
+		sendAnomalyAlerts.last[k] = now
+		l = append(l, an)
+	}
+	sendAnomalyAlerts.Unlock()
+	if len(l) == 0 {
+		return
+	}
+
+	err := deliverSendAnomalyAlert(log, accountName, l, action, now)
+	log.Check(err, "delivering alert about anomaly in outgoing messages", slog.String("account", accountName))
+}
+
+func deliverSendAnomalyAlert(log mlog.Log, accountName string, anomalies []store.SendAnomaly, action string, now time.Time) error {
+	acc, err := store.OpenAccount(log, mox.Conf.Static.Postmaster.Account, false)
+	if err != nil {
+		return fmt.Errorf("open postmaster account: %v", err)
+	}
+	defer func() {
+		err := acc.Close()
+		log.Check(err, "closing account")
+	}()
+
+	f, err := store.CreateMessageTemp(log, "sendanomaly-alert")
+	if err != nil {
+		return fmt.Errorf("creating temporary message file: %v", err)
+	}
+	defer store.CloseRemoveTempFile(log, f, "send anomaly alert")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Hi!\r\n\r\nAnomalies were detected in messages submitted by account %s, possibly a sign the account is compromised and used for sending spam.\r\n\r\n", accountName)
+	for _, an := range anomalies {
+		fmt.Fprintf(&b, "- %s\r\n", an.Text)
+	}
+	if action != "" {
+		fmt.Fprintf(&b, "\r\n%s\r\n", action)
+	}
+	fmt.Fprintf(&b, "\r\nCheers,\r\nmox\r\n")
+
+	n, err := fmt.Fprintf(f, "Date: %s\r\nSubject: Anomalies in outgoing messages for account %s\r\nContent-Type: text/plain; charset=utf-8\r\nContent-Transfer-Encoding: 8-bit\r\n\r\n%s", now.Format(message.RFC5322Z), accountName, b.String())
+	if err != nil {
+		return fmt.Errorf("writing send anomaly alert: %v", err)
+	}
+
+	m := store.Message{
+		Received: now,
+		Flags:    store.Flags{Flagged: true},
+		Size:     int64(n),
+	}
+	acc.WithWLock(func() {
+		err = acc.DeliverMailbox(log, mox.Conf.Static.Postmaster.Mailbox, &m, f)
+	})
+	if err != nil {
+		return fmt.Errorf("delivering send anomaly alert: %v", err)
+	}
+	return nil
+}
@@ -11,6 +11,7 @@ import (
 	"path/filepath"
 	"slices"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/mjl-/bstore"
@@ -143,6 +144,9 @@ func (x XOps) MessageFlagsAdd(ctx context.Context, log mlog.Log, acc *store.Acco
 	flags, keywords, err := store.ParseFlagsKeywords(flaglist)
 	x.Checkuserf(ctx, err, "parsing flags")
 
+	// Messages that were marked as junk or not junk, for junk re-evaluation.
+	var marked []store.Message
+
 	acc.WithRLock(func() {
 		var changes []store.Change
 
@@ -193,6 +197,9 @@ func (x XOps) MessageFlagsAdd(ctx context.Context, log mlog.Log, acc *store.Acco
 
 				changes = append(changes, m.ChangeFlags(oflags, mb))
 				retrain = append(retrain, m)
+				if m.Junk && !oflags.Junk || m.Notjunk && !oflags.Notjunk {
+					marked = append(marked, m)
+				}
 			}
 
 			if mb.ID != 0 {
@@ -213,6 +220,8 @@ func (x XOps) MessageFlagsAdd(ctx context.Context, log mlog.Log, acc *store.Acco
 
 		store.BroadcastChanges(acc, changes)
 	})
+
+	junkReevalMarked(ctx, log, acc, marked)
 }
 
 func (x XOps) MessageFlagsClear(ctx context.Context, log mlog.Log, acc *store.Account, messageIDs []int64, flaglist []string) {
@@ -286,6 +295,82 @@ func (x XOps) MessageFlagsClear(ctx context.Context, log mlog.Log, acc *store.Ac
 	})
 }
 
+// KeywordRenameTx changes keyword oldkw, and keywords below it in the hierarchy
+// (separated by "/"), into newkw in all messages and mailboxes of the account. If
+// newkw is empty, the keywords are removed. The number of changed messages is
+// returned, along with changes that must be broadcasted after committing tx.
+func (x XOps) KeywordRenameTx(ctx context.Context, log mlog.Log, acc *store.Account, tx *bstore.Tx, oldkw, newkw string, modseq *store.ModSeq) (int, []store.Change) {
+	rename := func(l []string) ([]string, bool) {
+		var changed bool
+		var nl []string
+		for _, kw := range l {
+			if kw != oldkw && !strings.HasPrefix(kw, oldkw+"/") {
+				nl = append(nl, kw)
+				continue
+			}
+			changed = true
+			if newkw != "" {
+				nl = append(nl, newkw+kw[len(oldkw):])
+			}
+		}
+		if !changed {
+			return l, false
+		}
+		slices.Sort(nl)
+		return slices.Compact(nl), true
+	}
+
+	var err error
+	if *modseq == 0 {
+		*modseq, err = acc.NextModSeq(tx)
+		x.Checkf(ctx, err, "assigning next modseq")
+	}
+
+	mailboxes := map[int64]store.Mailbox{}
+	origKeywords := map[int64][]string{}
+	err = bstore.QueryTx[store.Mailbox](tx).FilterEqual("Expunged", false).ForEach(func(mb store.Mailbox) error {
+		mailboxes[mb.ID] = mb
+		origKeywords[mb.ID] = mb.Keywords
+		return nil
+	})
+	x.Checkf(ctx, err, "listing mailboxes")
+
+	var changes []store.Change
+	q := bstore.QueryTx[store.Message](tx)
+	q.FilterEqual("Expunged", false)
+	q.FilterFn(func(m store.Message) bool {
+		_, changed := rename(m.Keywords)
+		return changed
+	})
+	l, err := q.List()
+	x.Checkf(ctx, err, "listing messages with keyword")
+	for _, m := range l {
+		m.Keywords, _ = rename(m.Keywords)
+		m.ModSeq = *modseq
+		err := tx.Update(&m)
+		x.Checkf(ctx, err, "updating message")
+
+		mb := mailboxes[m.MailboxID]
+		mb.Keywords, _ = store.MergeKeywords(mb.Keywords, m.Keywords)
+		mailboxes[m.MailboxID] = mb
+		changes = append(changes, m.ChangeFlags(m.Flags, mb))
+	}
+
+	// All messages have been changed, so the old keywords can be removed from mailboxes.
+	for _, mb := range mailboxes {
+		mb.Keywords, _ = rename(mb.Keywords)
+		if !mb.KeywordsChanged(store.Mailbox{Keywords: origKeywords[mb.ID]}) {
+			continue
+		}
+		mb.ModSeq = *modseq
+		err := tx.Update(&mb)
+		x.Checkf(ctx, err, "updating mailbox")
+		changes = append(changes, mb.ChangeKeywords())
+	}
+
+	return len(l), changes
+}
+
 // MailboxesMarkRead updates all messages in the referenced mailboxes as seen when
 // they aren't yet. The mailboxes are updated with their unread messages counts,
 // and the changes are propagated.
@@ -345,6 +430,9 @@ func (x XOps) MailboxesMarkRead(ctx context.Context, log mlog.Log, acc *store.Ac
 
 // MessageMove moves messages to the mailbox represented by mailboxName, or to mailboxID if mailboxName is empty.
 func (x XOps) MessageMove(ctx context.Context, log mlog.Log, acc *store.Account, messageIDs []int64, mailboxName string, mailboxID int64) {
+	// Messages that were marked as junk or not junk by the move, for junk re-evaluation.
+	var marked []store.Message
+
 	acc.WithWLock(func() {
 		var changes []store.Change
 
@@ -374,13 +462,27 @@ func (x XOps) MessageMove(ctx context.Context, log mlog.Log, acc *store.Account,
 				return
 			}
 
+			orig := make([]store.Message, len(messageIDs))
+			for i, id := range messageIDs {
+				orig[i] = x.messageID(ctx, tx, id)
+			}
+
 			var modseq store.ModSeq
 			newIDs, changes = x.MessageMoveTx(ctx, log, acc, tx, messageIDs, mbDst, &modseq)
+
+			for _, om := range orig {
+				m := x.messageID(ctx, tx, om.ID)
+				if m.Junk && !om.Junk || m.Notjunk && !om.Notjunk {
+					marked = append(marked, m)
+				}
+			}
 		})
 		newIDs = nil
 
 		store.BroadcastChanges(acc, changes)
 	})
+
+	junkReevalMarked(ctx, log, acc, marked)
 }
 
 // MessageMoveTx moves message to a new mailbox, which must be different than their
@@ -489,7 +591,9 @@ func (x XOps) MessageMoveTx(ctx context.Context, log mlog.Log, acc *store.Accoun
 			nm.Seen = true
 		}
 
-		nm.JunkFlagsForMailbox(mbDst, accConf)
+		nm.JunkFlagsForMove(mbSrc, mbDst, accConf)
+		err = store.ScreeningApproveMove(tx, mbSrc, mbDst, nm, accConf)
+		x.Checkf(ctx, err, "approving sender for screening")
 
 		err = tx.Update(&nm)
 		x.Checkf(ctx, err, "updating message with new mailbox")
@@ -522,6 +626,9 @@ func (x XOps) MessageMoveTx(ctx context.Context, log mlog.Log, acc *store.Accoun
 
 		mbDst.Keywords, _ = store.MergeKeywords(mbDst.Keywords, nm.Keywords)
 
+		err = store.SenderReputationUpdate(ctx, log, tx, &nm)
+		x.Checkf(ctx, err, "updating sender reputation after moving")
+
 		if accConf.JunkFilter != nil && nm.NeedsTraining() {
 			// Lazily open junk filter.
 			if jf == nil {
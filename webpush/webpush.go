@@ -0,0 +1,403 @@
+// Package webpush sends Web Push notifications (RFC 8030) about new messages to
+// browsers that subscribed through the webmail, also when no webmail tab is open.
+//
+// Payloads are encrypted for the browser (RFC 8291), requests to the push service
+// are authenticated with a VAPID key of the account (RFC 8292). Which messages
+// cause a notification is configured in the account settings.
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	cryptorand "crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/metrics"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxvar"
+	"github.com/mjl-/mox/store"
+)
+
+// Maximum number of subscriptions per account. When subscribing, the oldest
+// subscriptions are removed.
+const MaxSubscriptions = 20
+
+const (
+	recordSize = 4096      // Of encrypted payload, we always send a single record.
+	ttl        = 24 * 3600 // Seconds the push service keeps an undelivered notification.
+)
+
+// Endpoints come from users, so only public IPs can be reached.
+var httpClient = mox.PublicHTTPClient(30 * time.Second)
+
+// Payload is the JSON-encoded content of a notification, for the service worker
+// of the webmail.
+type Payload struct {
+	Title     string // From address of message.
+	Body      string // Subject of message.
+	Mailbox   string // Name of mailbox, for opening the message.
+	MessageID int64
+}
+
+// NewSubscription parses and checks the subscription details as provided by the
+// browser, with base64url-encoded keys.
+func NewSubscription(endpoint, p256dh, auth, userAgent string) (store.WebPushSubscription, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return store.WebPushSubscription{}, fmt.Errorf("parsing endpoint: %v", err)
+	} else if u.Scheme != "https" || u.Host == "" {
+		return store.WebPushSubscription{}, errors.New("endpoint must be https url")
+	} else if ip := net.ParseIP(u.Hostname()); ip != nil && !mox.PublicAllowIP(ip) || strings.EqualFold(u.Hostname(), "localhost") {
+		return store.WebPushSubscription{}, errors.New("endpoint must be a public host")
+	}
+	pub, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(p256dh, "="))
+	if err != nil {
+		return store.WebPushSubscription{}, fmt.Errorf("decoding p256dh public key: %v", err)
+	}
+	if _, err := ecdh.P256().NewPublicKey(pub); err != nil {
+		return store.WebPushSubscription{}, fmt.Errorf("parsing p256dh public key: %v", err)
+	}
+	secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(auth, "="))
+	if err != nil {
+		return store.WebPushSubscription{}, fmt.Errorf("decoding auth secret: %v", err)
+	} else if len(secret) != 16 {
+		return store.WebPushSubscription{}, fmt.Errorf("auth secret must be 16 bytes, got %d", len(secret))
+	}
+	return store.WebPushSubscription{Endpoint: endpoint, P256DH: pub, Auth: secret, UserAgent: userAgent}, nil
+}
+
+// KeyTx returns the VAPID key of the account, generating and storing a new key
+// if there is none yet.
+func KeyTx(tx *bstore.Tx) (*ecdsa.PrivateKey, error) {
+	k := store.WebPushKey{ID: 1}
+	err := tx.Get(&k)
+	if err == bstore.ErrAbsent {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), cryptorand.Reader)
+		if err != nil {
+			return nil, fmt.Errorf("generating key: %v", err)
+		}
+		k.PrivateKey, err = x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("marshal key: %v", err)
+		}
+		if err := tx.Insert(&k); err != nil {
+			return nil, fmt.Errorf("storing key: %v", err)
+		}
+		return key, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("get key: %v", err)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(k.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("parsing key: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("key is %T, expected ecdsa", key)
+	}
+	return ecKey, nil
+}
+
+// PublicKey returns the public key as uncompressed P-256 point, as used in the
+// "applicationServerKey" when subscribing in the browser.
+func PublicKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	pub, err := key.PublicKey.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	return pub.Bytes(), nil
+}
+
+// hkdf returns the first n bytes (at most 32) of HKDF-SHA256 (RFC 5869).
+func hkdf(salt, ikm, info []byte, n int) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(ikm)
+	prk := mac.Sum(nil)
+	mac = hmac.New(sha256.New, prk)
+	mac.Write(info)
+	mac.Write([]byte{1})
+	return mac.Sum(nil)[:n]
+}
+
+// encrypt returns the payload encrypted for the subscription with the
+// "aes128gcm" content encoding (RFC 8188) as used by Web Push (RFC 8291), as a
+// single record. The ephemeral key and salt are parameters for testing.
+func encrypt(sub store.WebPushSubscription, asKey *ecdh.PrivateKey, salt, payload []byte) ([]byte, error) {
+	if len(payload)+1+16 > recordSize {
+		return nil, fmt.Errorf("payload too large")
+	}
+	uaPub, err := ecdh.P256().NewPublicKey(sub.P256DH)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key of subscription: %v", err)
+	}
+	secret, err := asKey.ECDH(uaPub)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement: %v", err)
+	}
+	asPub := asKey.PublicKey().Bytes()
+
+	keyInfo := slices.Concat([]byte("WebPush: info\x00"), sub.P256DH, asPub)
+	ikm := hkdf(sub.Auth, secret, keyInfo, 32)
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header: salt, record size, key id length and key id, which is our public key.
+	var b bytes.Buffer
+	b.Write(salt)
+	binary.Write(&b, binary.BigEndian, uint32(recordSize))
+	b.WriteByte(byte(len(asPub)))
+	b.Write(asPub)
+	// Delimiter 2 indicates the last record.
+	return gcm.Seal(b.Bytes(), nonce, append(slices.Clone(payload), 2), nil), nil
+}
+
+// vapidAuthorization returns the value for the Authorization header for a request
+// to the push service at endpoint.
+func vapidAuthorization(key *ecdsa.PrivateKey, endpoint string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("parsing endpoint: %v", err)
+	}
+	claims := struct {
+		Aud string `json:"aud"`
+		Exp int64  `json:"exp"`
+		Sub string `json:"sub"`
+	}{u.Scheme + "://" + u.Host, now.Add(12 * time.Hour).Unix(), "mailto:postmaster@" + mox.Conf.Static.HostnameDomain.ASCII}
+	claimsBuf, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal claims: %v", err)
+	}
+	enc := base64.RawURLEncoding
+	token := enc.EncodeToString([]byte(`{"typ":"JWT","alg":"ES256"}`)) + "." + enc.EncodeToString(claimsBuf)
+	h := sha256.Sum256([]byte(token))
+	r, s, err := ecdsa.Sign(cryptorand.Reader, key, h[:])
+	if err != nil {
+		return "", fmt.Errorf("signing token: %v", err)
+	}
+	// JWS ES256 signatures are r and s as 32 bytes each.
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	pub, err := PublicKey(key)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("vapid t=%s.%s, k=%s", token, enc.EncodeToString(sig), enc.EncodeToString(pub)), nil
+}
+
+// errGone is returned by send when the push service indicates the subscription
+// no longer exists.
+var errGone = errors.New("subscription gone")
+
+// send delivers the payload to the push service of the subscription.
+func send(ctx context.Context, key *ecdsa.PrivateKey, sub store.WebPushSubscription, payload []byte) error {
+	asKey, err := ecdh.P256().GenerateKey(cryptorand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating ephemeral key: %v", err)
+	}
+	salt := make([]byte, 16)
+	cryptorand.Read(salt)
+	body, err := encrypt(sub, asKey, salt, payload)
+	if err != nil {
+		return fmt.Errorf("encrypting payload: %v", err)
+	}
+	authz, err := vapidAuthorization(key, sub.Endpoint, time.Now())
+	if err != nil {
+		return fmt.Errorf("vapid authorization: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("new request: %v", err)
+	}
+	req.Header.Set("User-Agent", fmt.Sprintf("mox/%s (webpush)", moxvar.Version))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", fmt.Sprintf("%d", ttl))
+	req.Header.Set("Urgency", "normal")
+	req.Header.Set("Authorization", authz)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("http transaction: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return errGone
+	case resp.StatusCode/100 != 2:
+		return fmt.Errorf("http status %q, expected 2xx", resp.Status)
+	}
+	return nil
+}
+
+// Matches returns whether a new message in mailbox from the sender address
+// causes a notification according to the settings.
+func Matches(settings store.Settings, mailbox, fromLocalpart, fromDomain string) bool {
+	if len(settings.NotifyMailboxes) == 0 {
+		if mailbox != "Inbox" {
+			return false
+		}
+	} else if !slices.Contains(settings.NotifyMailboxes, mailbox) {
+		return false
+	}
+	if len(settings.NotifySenders) == 0 {
+		return true
+	}
+	domain := strings.ToLower(fromDomain)
+	addr := strings.ToLower(fromLocalpart) + "@" + domain
+	for _, s := range settings.NotifySenders {
+		s = strings.ToLower(s)
+		if s == addr || s == "@"+domain {
+			return true
+		}
+	}
+	return false
+}
+
+// notification is a payload to send to a subscription.
+type notification struct {
+	sub     store.WebPushSubscription
+	payload []byte
+}
+
+// prepare returns the key and the notifications to send for the delivered
+// message. The message is read again from the database, it may have been moved
+// or marked as read by rules during delivery.
+func prepare(acc *store.Account, messageID int64, part message.Part) (*ecdsa.PrivateKey, []notification, error) {
+	var key *ecdsa.PrivateKey
+	var l []notification
+	err := acc.DB.Write(context.Background(), func(tx *bstore.Tx) error {
+		subs, err := bstore.QueryTx[store.WebPushSubscription](tx).List()
+		if err != nil {
+			return fmt.Errorf("listing subscriptions: %v", err)
+		} else if len(subs) == 0 {
+			return nil
+		}
+
+		m := store.Message{ID: messageID}
+		if err := tx.Get(&m); err != nil {
+			return fmt.Errorf("get message: %v", err)
+		} else if m.Expunged || m.Seen || m.Junk {
+			return nil
+		}
+		mb := store.Mailbox{ID: m.MailboxID}
+		if err := tx.Get(&mb); err != nil {
+			return fmt.Errorf("get mailbox: %v", err)
+		}
+		settings := store.Settings{ID: 1}
+		if err := tx.Get(&settings); err != nil {
+			return fmt.Errorf("get settings: %v", err)
+		}
+		if !Matches(settings, mb.Name, string(m.MsgFromLocalpart), m.MsgFromDomain) {
+			return nil
+		}
+
+		key, err = KeyTx(tx)
+		if err != nil {
+			return err
+		}
+
+		p := Payload{Title: string(m.MsgFromLocalpart) + "@" + m.MsgFromDomain, Mailbox: mb.Name, MessageID: m.ID}
+		if part.Envelope != nil {
+			if len(part.Envelope.From) > 0 && part.Envelope.From[0].Name != "" {
+				p.Title = part.Envelope.From[0].Name
+			}
+			p.Body = part.Envelope.Subject
+		}
+		// Keep the payload well below the maximum record size.
+		if r := []rune(p.Title); len(r) > 100 {
+			p.Title = string(r[:100])
+		}
+		if r := []rune(p.Body); len(r) > 300 {
+			p.Body = string(r[:300])
+		}
+		buf, err := json.Marshal(p)
+		if err != nil {
+			return fmt.Errorf("marshal payload: %v", err)
+		}
+		for _, sub := range subs {
+			l = append(l, notification{sub, buf})
+		}
+		return nil
+	})
+	return key, l, err
+}
+
+// deliver sends the notifications, removing subscriptions that are gone.
+func deliver(log mlog.Log, acc *store.Account, key *ecdsa.PrivateKey, l []notification) {
+	for _, n := range l {
+		err := send(context.Background(), key, n.sub, n.payload)
+		if err == errGone {
+			log.Info("removing web push subscription that is gone", slog.Int64("id", n.sub.ID))
+			err := acc.DB.Delete(context.Background(), &store.WebPushSubscription{ID: n.sub.ID})
+			log.Check(err, "removing web push subscription")
+		} else if err != nil {
+			log.Errorx("sending web push notification", err, slog.Int64("id", n.sub.ID))
+		}
+	}
+}
+
+// Notify sends notifications about the message that was delivered to the
+// account, if it has subscriptions and the message matches the settings.
+// Requests to push services are made in the background.
+func Notify(log mlog.Log, acc *store.Account, messageID int64, part message.Part) error {
+	key, l, err := prepare(acc, messageID, part)
+	if err != nil || len(l) == 0 {
+		return err
+	}
+
+	// We keep a reference to the account while sending.
+	nacc, err := store.OpenAccount(log, acc.Name, false)
+	if err != nil {
+		return fmt.Errorf("open account: %v", err)
+	}
+	go func() {
+		defer func() {
+			x := recover()
+			if x != nil {
+				log.Error("unhandled panic while sending web push notifications", slog.Any("x", x))
+				debug.PrintStack()
+				metrics.PanicInc(metrics.Webpush)
+			}
+			err := nacc.Close()
+			log.Check(err, "closing account after sending web push notifications")
+		}()
+		deliver(log, nacc, key, l)
+	}()
+	return nil
+}
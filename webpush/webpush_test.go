@@ -0,0 +1,248 @@
+package webpush
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjl-/bstore"
+
+	"github.com/mjl-/mox/message"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/store"
+)
+
+var ctxbg = context.Background()
+var pkglog = mlog.New("webpush", nil)
+
+func tcheck(t *testing.T, err error, msg string) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("%s: %s", msg, err)
+	}
+}
+
+func tcompare(t *testing.T, got, expect any) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expect) {
+		t.Fatalf("got:\n%#v\nexpected:\n%#v", got, expect)
+	}
+}
+
+// decrypt is the browser side of encrypt.
+func decrypt(t *testing.T, uaKey *ecdh.PrivateKey, auth, body []byte) []byte {
+	t.Helper()
+	salt := body[:16]
+	tcompare(t, binary.BigEndian.Uint32(body[16:20]), uint32(recordSize))
+	idlen := int(body[20])
+	asPub, err := ecdh.P256().NewPublicKey(body[21 : 21+idlen])
+	tcheck(t, err, "parse as public key")
+	secret, err := uaKey.ECDH(asPub)
+	tcheck(t, err, "ecdh")
+	keyInfo := slices.Concat([]byte("WebPush: info\x00"), uaKey.PublicKey().Bytes(), asPub.Bytes())
+	ikm := hkdf(auth, secret, keyInfo, 32)
+	cek := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+	block, err := aes.NewCipher(cek)
+	tcheck(t, err, "aes")
+	gcm, err := cipher.NewGCM(block)
+	tcheck(t, err, "gcm")
+	plain, err := gcm.Open(nil, nonce, body[21+idlen:], nil)
+	tcheck(t, err, "decrypt")
+	if len(plain) == 0 || plain[len(plain)-1] != 2 {
+		t.Fatalf("missing last record delimiter")
+	}
+	return plain[:len(plain)-1]
+}
+
+func TestWebPush(t *testing.T) {
+	os.RemoveAll("../testdata/webpush/data")
+	mox.Context = ctxbg
+	mox.ConfigStaticPath = filepath.FromSlash("../testdata/webpush/mox.conf")
+	mox.MustLoadConfig(true, false)
+	err := store.Init(ctxbg)
+	tcheck(t, err, "store init")
+	defer func() {
+		err := store.Close()
+		tcheck(t, err, "store close")
+	}()
+	defer store.Switchboard()()
+
+	acc, err := store.OpenAccount(pkglog, "mjl", false)
+	tcheck(t, err, "open account")
+	defer func() {
+		err := acc.Close()
+		pkglog.Check(err, "closing account")
+		acc.WaitClosed()
+	}()
+
+	// Browser key and subscription.
+	uaKey, err := ecdh.P256().GenerateKey(strings.NewReader(strings.Repeat("x", 64)))
+	tcheck(t, err, "generate browser key")
+	auth := []byte("0123456789abcdef")
+	enc := base64.RawURLEncoding
+	_, err = NewSubscription("http://push.example/x", enc.EncodeToString(uaKey.PublicKey().Bytes()), enc.EncodeToString(auth), "")
+	if err == nil {
+		t.Fatalf("expected error for non-https endpoint")
+	}
+	_, err = NewSubscription("https://127.0.0.1/x", enc.EncodeToString(uaKey.PublicKey().Bytes()), enc.EncodeToString(auth), "")
+	if err == nil {
+		t.Fatalf("expected error for loopback endpoint")
+	}
+	_, err = NewSubscription("https://push.example/x", enc.EncodeToString([]byte("bogus")), enc.EncodeToString(auth), "")
+	if err == nil {
+		t.Fatalf("expected error for invalid public key")
+	}
+	_, err = NewSubscription("https://push.example/x", enc.EncodeToString(uaKey.PublicKey().Bytes()), enc.EncodeToString(auth[:8]), "")
+	if err == nil {
+		t.Fatalf("expected error for short auth secret")
+	}
+
+	// Encryption round trip.
+	sub, err := NewSubscription("https://push.example/x", enc.EncodeToString(uaKey.PublicKey().Bytes()), enc.EncodeToString(auth), "test")
+	tcheck(t, err, "new subscription")
+	asKey, err := ecdh.P256().GenerateKey(strings.NewReader(strings.Repeat("y", 64)))
+	tcheck(t, err, "generate key")
+	body, err := encrypt(sub, asKey, []byte("saltsaltsaltsalt"), []byte("hello"))
+	tcheck(t, err, "encrypt")
+	tcompare(t, string(decrypt(t, uaKey, auth, body)), "hello")
+	_, err = encrypt(sub, asKey, []byte("saltsaltsaltsalt"), make([]byte, recordSize))
+	if err == nil {
+		t.Fatalf("expected error for too large payload")
+	}
+
+	// The VAPID key is generated once.
+	var key, key2 *ecdsa.PrivateKey
+	err = acc.DB.Write(ctxbg, func(tx *bstore.Tx) error {
+		key, err = KeyTx(tx)
+		if err != nil {
+			return err
+		}
+		key2, err = KeyTx(tx)
+		return err
+	})
+	tcheck(t, err, "get key")
+	tcompare(t, key.Equal(key2), true)
+
+	// VAPID token is signed by the key.
+	authz, err := vapidAuthorization(key, "https://push.example:8443/x", time.Now())
+	tcheck(t, err, "vapid authorization")
+	token, k, ok := strings.Cut(strings.TrimPrefix(authz, "vapid t="), ", k=")
+	if !ok {
+		t.Fatalf("bad authorization header %q", authz)
+	}
+	pub, err := PublicKey(key)
+	tcheck(t, err, "public key")
+	tcompare(t, k, enc.EncodeToString(pub))
+	t0 := strings.Split(token, ".")
+	tcompare(t, len(t0), 3)
+	claimsBuf, err := enc.DecodeString(t0[1])
+	tcheck(t, err, "decode claims")
+	var claims map[string]any
+	err = json.Unmarshal(claimsBuf, &claims)
+	tcheck(t, err, "parse claims")
+	tcompare(t, claims["aud"], "https://push.example:8443")
+	tcompare(t, claims["sub"], "mailto:postmaster@mox.example")
+	sig, err := enc.DecodeString(t0[2])
+	tcheck(t, err, "decode signature")
+	h := sha256.Sum256([]byte(t0[0] + "." + t0[1]))
+	if !ecdsa.Verify(&key.PublicKey, h[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])) {
+		t.Fatalf("vapid signature does not verify")
+	}
+
+	// Rules from settings.
+	settings := store.Settings{}
+	tcompare(t, Matches(settings, "Inbox", "a", "remote.example"), true)
+	tcompare(t, Matches(settings, "Archive", "a", "remote.example"), false)
+	settings = store.Settings{NotifyMailboxes: []string{"Archive"}, NotifySenders: []string{"boss@work.example", "@friends.example"}}
+	tcompare(t, Matches(settings, "Inbox", "boss", "work.example"), false)
+	tcompare(t, Matches(settings, "Archive", "Boss", "WORK.example"), true)
+	tcompare(t, Matches(settings, "Archive", "other", "work.example"), false)
+	tcompare(t, Matches(settings, "Archive", "any", "friends.example"), true)
+
+	// Deliver a message, and send a notification to a push service.
+	var gotBody []byte
+	var gotHeader http.Header
+	code := http.StatusCreated
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotHeader = r.Header
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(code)
+	}))
+	defer srv.Close()
+
+	// Internal addresses cannot be reached, also not through DNS.
+	_, err = httpClient.Post(srv.URL+"/push", "application/octet-stream", nil)
+	if !errors.Is(err, mox.ErrAddressNotAllowed) {
+		t.Fatalf("got err %v, expected ErrAddressNotAllowed", err)
+	}
+	httpClient = srv.Client()
+
+	sub.Endpoint = srv.URL + "/push"
+	err = acc.DB.Insert(ctxbg, &sub)
+	tcheck(t, err, "insert subscription")
+
+	msg := "From: Boss <boss@work.example>\r\nTo: <mjl@mox.example>\r\nSubject: urgent\r\n\r\nhi\r\n"
+	m := store.Message{Size: int64(len(msg)), MsgFromLocalpart: "boss", MsgFromDomain: "work.example"}
+	f, err := store.CreateMessageTemp(pkglog, "webpush-test")
+	tcheck(t, err, "create temp message")
+	defer store.CloseRemoveTempFile(pkglog, f, "test message")
+	_, err = f.Write([]byte(msg))
+	tcheck(t, err, "write message")
+	acc.WithWLock(func() {
+		err = acc.DeliverMailbox(pkglog, "Inbox", &m, f)
+	})
+	tcheck(t, err, "deliver message")
+	part, err := message.Parse(pkglog.Logger, false, strings.NewReader(msg))
+	tcheck(t, err, "parse message")
+
+	xkey, l, err := prepare(acc, m.ID, part)
+	tcheck(t, err, "prepare")
+	tcompare(t, len(l), 1)
+	deliver(pkglog, acc, xkey, l)
+	tcompare(t, gotHeader.Get("Content-Encoding"), "aes128gcm")
+	if !strings.HasPrefix(gotHeader.Get("Authorization"), "vapid t=") {
+		t.Fatalf("missing vapid authorization, got %q", gotHeader.Get("Authorization"))
+	}
+	var p Payload
+	err = json.Unmarshal(decrypt(t, uaKey, auth, gotBody), &p)
+	tcheck(t, err, "parse payload")
+	tcompare(t, p, Payload{"Boss", "urgent", "Inbox", m.ID})
+
+	// No notification when settings don't match.
+	err = acc.DB.Update(ctxbg, &store.Settings{ID: 1, NotifySenders: []string{"@other.example"}})
+	tcheck(t, err, "update settings")
+	_, l, err = prepare(acc, m.ID, part)
+	tcheck(t, err, "prepare")
+	tcompare(t, len(l), 0)
+	err = acc.DB.Update(ctxbg, &store.Settings{ID: 1})
+	tcheck(t, err, "update settings")
+
+	// Subscription is removed when the push service says it is gone.
+	code = http.StatusGone
+	xkey, l, err = prepare(acc, m.ID, part)
+	tcheck(t, err, "prepare")
+	deliver(pkglog, acc, xkey, l)
+	n, err := bstore.QueryDB[store.WebPushSubscription](ctxbg, acc.DB).Count()
+	tcheck(t, err, "count subscriptions")
+	tcompare(t, n, 0)
+}
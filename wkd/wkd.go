@@ -0,0 +1,246 @@
+// Package wkd implements the OpenPGP Web Key Directory (WKD), for looking up
+// OpenPGP public keys for email addresses over HTTPS.
+//
+// Keys are served at a URL with a hash of the localpart of an address. With the
+// "advanced" method, at
+// "https://openpgpkey.<domain>/.well-known/openpgpkey/<domain>/hu/<hash>?l=<localpart>",
+// and with the "direct" method, used when the openpgpkey subdomain does not
+// exist, at "https://<domain>/.well-known/openpgpkey/hu/<hash>?l=<localpart>".
+//
+// See draft-koch-openpgp-webkey-service.
+//
+// Keys are parsed with golang.org/x/crypto/openpgp, which is deprecated and
+// frozen. It supports RSA, DSA/ElGamal and ECDSA/ECDH keys with the NIST curves,
+// but not Ed25519/Curve25519 keys, the default for keys created with current
+// versions of GnuPG, or v5/v6 keys. Such keys are rejected with
+// ErrKeyUnsupported, see CheckKeyAlgorithms.
+package wkd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/moxio"
+	"github.com/mjl-/mox/smtp"
+	"github.com/mjl-/mox/stub"
+)
+
+var (
+	HTTPClientObserve func(ctx context.Context, log *slog.Logger, pkg, method string, statusCode int, err error, start time.Time) = stub.HTTPClientObserveIgnore
+)
+
+// Lookup errors.
+var (
+	ErrNoKey     = errors.New("wkd: no key served")    // If no key is served for the address with either method.
+	ErrKeyFetch  = errors.New("wkd: cannot fetch key") // E.g. for HTTP request errors.
+	ErrKeySyntax = errors.New("wkd: invalid key")
+
+	// For keys with algorithms that cannot be used, see CheckKeyAlgorithms.
+	ErrKeyUnsupported = errors.New("wkd: unsupported key algorithm, only rsa, dsa/elgamal and ecdsa/ecdh keys with nist p-256/p-384/p-521 curves are supported")
+)
+
+// MaxKeySize is the maximum size of served keys, in bytes.
+const MaxKeySize = 256 * 1024
+
+const zbase32Alphabet = "ybndrfg8ejkmcpqxot1uwisza345h769"
+
+// Hash returns the z-base-32 encoded SHA-1 hash of the localpart, after mapping
+// ASCII upper case to lower case, as used in WKD URLs.
+func Hash(localpart smtp.Localpart) string {
+	lp := strings.Map(func(c rune) rune {
+		if c >= 'A' && c <= 'Z' {
+			return c + ('a' - 'A')
+		}
+		return c
+	}, string(localpart))
+	h := sha1.Sum([]byte(lp))
+
+	// 160 bits encode into exactly 32 characters of 5 bits.
+	var sb strings.Builder
+	var v, n uint
+	for _, b := range h {
+		v = v<<8 | uint(b)
+		n += 8
+		for n >= 5 {
+			n -= 5
+			sb.WriteByte(zbase32Alphabet[(v>>n)&0x1f])
+		}
+	}
+	return sb.String()
+}
+
+// AdvancedURL returns the URL for the key of addr with the advanced method.
+func AdvancedURL(addr smtp.Address) string {
+	return fmt.Sprintf("https://openpgpkey.%s/.well-known/openpgpkey/%s/hu/%s?l=%s", addr.Domain.ASCII, addr.Domain.ASCII, Hash(addr.Localpart), url.QueryEscape(string(addr.Localpart)))
+}
+
+// DirectURL returns the URL for the key of addr with the direct method.
+func DirectURL(addr smtp.Address) string {
+	return fmt.Sprintf("https://%s/.well-known/openpgpkey/hu/%s?l=%s", addr.Domain.ASCII, Hash(addr.Localpart), url.QueryEscape(string(addr.Localpart)))
+}
+
+// HTTPClient is used by Lookup for HTTP requests. Hosts are derived from
+// addresses of recipients and senders, so it only connects to public addresses,
+// and only follows redirects to https URLs.
+var HTTPClient = mox.PublicHTTPClient(30*time.Second, "https")
+
+// Lookup fetches the public keys for addr from the web key directory of its
+// domain. The advanced method is tried first, the direct method only if the
+// openpgpkey subdomain does not exist or serves no key. Only keys with a user ID
+// for addr are returned. If none are found, ErrNoKey is returned.
+func Lookup(ctx context.Context, elog *slog.Logger, addr smtp.Address) (keys openpgp.EntityList, rerr error) {
+	log := mlog.New("wkd", elog)
+	start := time.Now()
+	defer func() {
+		log.Debugx("wkd lookup result", rerr,
+			slog.Any("address", addr),
+			slog.Int("nkeys", len(keys)),
+			slog.Duration("duration", time.Since(start)))
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	buf, err := fetch(ctx, log, AdvancedURL(addr))
+	if errors.Is(err, ErrNoKey) {
+		buf, err = fetch(ctx, log, DirectURL(addr))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	l, err := openpgp.ReadKeyRing(bytes.NewReader(buf))
+	if err != nil {
+		if xerr := CheckKeyAlgorithms(buf); xerr != nil {
+			return nil, xerr
+		}
+		return nil, fmt.Errorf("%w: %s", ErrKeySyntax, err)
+	}
+	for _, e := range l {
+		if EntityHasAddress(e, addr) {
+			keys = append(keys, e)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, ErrNoKey
+	}
+	return keys, nil
+}
+
+// OIDs of the NIST curves supported for ECDSA and ECDH keys.
+var supportedCurveOIDs = [][]byte{
+	{0x2a, 0x86, 0x48, 0xce, 0x3d, 0x03, 0x01, 0x07}, // P-256
+	{0x2b, 0x81, 0x04, 0x00, 0x22},                   // P-384
+	{0x2b, 0x81, 0x04, 0x00, 0x23},                   // P-521
+}
+
+// CheckKeyAlgorithms returns an error wrapping ErrKeyUnsupported if a key or
+// subkey in buf, binary OpenPGP key material, has an algorithm that cannot be
+// used. Openpgp.ReadKeyRing skips such keys, or returns an unhelpful error. Other
+// problems are not reported.
+func CheckKeyAlgorithms(buf []byte) error {
+	r := packet.NewOpaqueReader(bytes.NewReader(buf))
+	for {
+		op, err := r.Next()
+		if err != nil {
+			return nil
+		}
+		// Public key, public subkey, secret key and secret subkey packets, RFC 9580 section 5.5.
+		if op.Tag != 6 && op.Tag != 14 && op.Tag != 5 && op.Tag != 7 || len(op.Contents) < 6 {
+			continue
+		}
+		c := op.Contents
+		if c[0] != 4 {
+			if c[0] > 4 {
+				return fmt.Errorf("%w: version %d key", ErrKeyUnsupported, c[0])
+			}
+			continue
+		}
+		switch algo := c[5]; algo {
+		case 22, 27, 28:
+			return fmt.Errorf("%w: ed25519/ed448 key (algorithm %d)", ErrKeyUnsupported, algo)
+		case 25, 26:
+			return fmt.Errorf("%w: x25519/x448 key (algorithm %d)", ErrKeyUnsupported, algo)
+		case 18, 19:
+			if len(c) < 7 || len(c) < 7+int(c[6]) {
+				continue
+			}
+			oid := c[7 : 7+int(c[6])]
+			if !slices.ContainsFunc(supportedCurveOIDs, func(o []byte) bool { return bytes.Equal(o, oid) }) {
+				return fmt.Errorf("%w: elliptic curve with oid %x, e.g. curve25519", ErrKeyUnsupported, oid)
+			}
+		}
+	}
+}
+
+// EntityHasAddress returns whether one of the user IDs of key e has email
+// address addr.
+func EntityHasAddress(e *openpgp.Entity, addr smtp.Address) bool {
+	for _, a := range EntityAddresses(e) {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// EntityAddresses returns the valid email addresses in the user IDs of key e.
+func EntityAddresses(e *openpgp.Entity) []smtp.Address {
+	var l []smtp.Address
+	for _, id := range e.Identities {
+		if id.UserId == nil || id.UserId.Email == "" {
+			continue
+		}
+		if a, err := smtp.ParseAddress(id.UserId.Email); err == nil {
+			l = append(l, a)
+		}
+	}
+	return l
+}
+
+func fetch(ctx context.Context, log mlog.Log, u string) ([]byte, error) {
+	start := time.Now()
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: http request: %s", ErrKeyFetch, err)
+	}
+	resp, err := HTTPClient.Do(req)
+	if dns.IsNotFound(err) {
+		return nil, ErrNoKey
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: http get: %w", ErrKeyFetch, err)
+	}
+	HTTPClientObserve(ctx, log.Logger, "wkd", req.Method, resp.StatusCode, err, start)
+	defer func() {
+		err := resp.Body.Close()
+		log.Check(err, "close body response")
+	}()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNoKey
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: http status %s while status 200 is required", ErrKeyFetch, resp.Status)
+	}
+	buf, err := io.ReadAll(&moxio.LimitReader{R: resp.Body, Limit: MaxKeySize})
+	if err != nil {
+		return nil, fmt.Errorf("%w: reading key: %s", ErrKeyFetch, err)
+	}
+	return buf, nil
+}
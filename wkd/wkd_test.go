@@ -0,0 +1,170 @@
+package wkd
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mjl-/adns"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+
+	"github.com/mjl-/mox/dns"
+	"github.com/mjl-/mox/mlog"
+	"github.com/mjl-/mox/mox-"
+	"github.com/mjl-/mox/smtp"
+)
+
+func TestHash(t *testing.T) {
+	// Example from the draft.
+	if h := Hash("Joe.Doe"); h != "iy9q119eutrkn8s1mk4r39qejnbu3n5q" {
+		t.Fatalf("got hash %q", h)
+	}
+	addr := smtp.Address{Localpart: "Joe.Doe", Domain: dns.Domain{ASCII: "example.org"}}
+	if u := AdvancedURL(addr); u != "https://openpgpkey.example.org/.well-known/openpgpkey/example.org/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe" {
+		t.Fatalf("got advanced url %q", u)
+	}
+	if u := DirectURL(addr); u != "https://example.org/.well-known/openpgpkey/hu/iy9q119eutrkn8s1mk4r39qejnbu3n5q?l=Joe.Doe" {
+		t.Fatalf("got direct url %q", u)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	log := mlog.New("wkd", nil)
+
+	e, err := openpgp.NewEntity("mjl", "", "mjl@mox.example", nil)
+	if err != nil {
+		t.Fatalf("new entity: %v", err)
+	}
+	var key bytes.Buffer
+	if err := e.Serialize(&key); err != nil {
+		t.Fatalf("serialize key: %v", err)
+	}
+
+	// Only the direct method is served.
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Host != "mox.example" || r.URL.Path != "/.well-known/openpgpkey/hu/"+Hash("mjl") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(key.Bytes())
+	}))
+	defer srv.Close()
+
+	origTransport := HTTPClient.Transport
+	defer func() {
+		HTTPClient.Transport = origTransport
+	}()
+	HTTPClient.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			if strings.HasPrefix(addr, "openpgpkey.") {
+				return nil, &adns.DNSError{IsNotFound: true}
+			}
+			return net.Dial(network, srv.Listener.Addr().String())
+		},
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+
+	test := func(addr string, expErr error) {
+		t.Helper()
+		a, err := smtp.ParseAddress(addr)
+		if err != nil {
+			t.Fatalf("parse address: %v", err)
+		}
+		keys, err := Lookup(context.Background(), log.Logger, a)
+		if (err == nil) != (expErr == nil) || err != nil && !errors.Is(err, expErr) {
+			t.Fatalf("got err %v, expected %v", err, expErr)
+		}
+		if err == nil && (len(keys) != 1 || keys[0].PrimaryKey.Fingerprint != e.PrimaryKey.Fingerprint) {
+			t.Fatalf("unexpected keys %v", keys)
+		}
+	}
+	test("mjl@mox.example", nil)
+	test("MJL@mox.example", ErrNoKey) // Served, but key has a different address.
+	test("other@mox.example", ErrNoKey)
+}
+
+// Ed25519 primary key with cv25519 encryption subkey, as generated by current GnuPG.
+const ed25519Key = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mDMEatGW4BYJKwYBBAHaRw8BAQdAXBfDErIhQ29EeMXPUgUxduQQfWrGatATaM5r
++2b+/r+0GlRlc3QgPGVkMjU1MTlAbW94LmV4YW1wbGU+iJAEExYIADgWIQSk/A2J
+EcI+oFI1olIaGX2/EP93aQUCatGW4AIbAwULCQgHAgYVCgkICwIEFgIDAQIeAQIX
+gAAKCRAaGX2/EP93aQwBAQC1ED/ortPLZSdd8RoYO6/A+Pe6JCQxYUS/j/2aCrVT
+AAD/SHKYSVZFdR9wbbbt2QbXGGXEpad0pTocYfa7P45rKAS4OARq0ZbhEgorBgEE
+AZdVAQUBAQdA3nXd6gemoVMPm924iDC2SAbeVBGDpVIZPL1YWY+XsQIDAQgHiHgE
+GBYIACAWIQSk/A2JEcI+oFI1olIaGX2/EP93aQUCatGW4QIbDAAKCRAaGX2/EP93
+aefTAQCSMzEEYTVKWdDcOOcPfCeV/IIEtEZ56/AZHXXBVo6xmwD/QopOyNR/ZXX2
+W/guwBAd8SOs9x1l3/qFRlyDQ7w5yAQ=
+=j5vG
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+// NIST P-256 primary key with cv25519 encryption subkey.
+const p256Cv25519Key = `-----BEGIN PGP PUBLIC KEY BLOCK-----
+
+mFIEatGW5BMIKoZIzj0DAQcCAwQVKOpnfKzfSu7dF31jyVWFtZ4oL9ZXZSwu7BgV
+YvIO6Axi0N0gNrx8MeGvCERLC/NI+rwOR/8jbxt9nv2kTj3FtBdUZXN0IDxwMjU2
+QG1veC5leGFtcGxlPoiQBBMTCAA4FiEEtE1fYS4DcVbK3gwvYuNBCeClTl8FAmrR
+luQCGwMFCwkIBwIGFQoJCAsCBBYCAwECHgECF4AACgkQYuNBCeClTl9V1QEA2qNB
+lK40ag5pxrtbc651fZjuy8EfuD+sjcdXIgm1yh8BAOvgYXCBpBMAwEs2RnXr/FBU
+q3woP22Jow/iJ4sRQvb4uDgEatGW5BIKKwYBBAGXVQEFAQEHQLbK+FK7sARhVAye
+zQhsy3/j3gUEjNbVYivg2VoZLP9sAwEIB4h4BBgTCAAgFiEEtE1fYS4DcVbK3gwv
+YuNBCeClTl8FAmrRluQCGwwACgkQYuNBCeClTl9p6QEA4ddaChCiukqCIkFTa0WV
+aqDqFwiR6jBhZXmO1e8Av4QBAJQAffn6DsVuQem3iQZwiQZeBOSo2eENm7Es9rMO
+NRHy
+=JZ2T
+-----END PGP PUBLIC KEY BLOCK-----
+`
+
+func TestCheckKeyAlgorithms(t *testing.T) {
+	e, err := openpgp.NewEntity("mjl", "", "mjl@mox.example", nil)
+	if err != nil {
+		t.Fatalf("new entity: %v", err)
+	}
+	var key bytes.Buffer
+	if err := e.Serialize(&key); err != nil {
+		t.Fatalf("serialize key: %v", err)
+	}
+	if err := CheckKeyAlgorithms(key.Bytes()); err != nil {
+		t.Fatalf("check rsa key: %v", err)
+	}
+
+	unarmor := func(s string) []byte {
+		t.Helper()
+		b, err := armor.Decode(strings.NewReader(s))
+		if err != nil {
+			t.Fatalf("armor decode: %v", err)
+		}
+		buf, err := io.ReadAll(b.Body)
+		if err != nil {
+			t.Fatalf("read armored body: %v", err)
+		}
+		return buf
+	}
+	for _, k := range []string{ed25519Key, p256Cv25519Key} {
+		if err := CheckKeyAlgorithms(unarmor(k)); !errors.Is(err, ErrKeyUnsupported) {
+			t.Fatalf("got err %v, expected ErrKeyUnsupported", err)
+		}
+	}
+}
+
+// Keys are not fetched from internal addresses.
+func TestPublicAddress(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to internal address")
+	}))
+	defer srv.Close()
+
+	_, err := fetch(context.Background(), mlog.New("wkd", nil), srv.URL+"/.well-known/openpgpkey/hu/"+Hash("mjl"))
+	if !errors.Is(err, ErrKeyFetch) || !errors.Is(err, mox.ErrAddressNotAllowed) {
+		t.Fatalf("got err %v, expected ErrKeyFetch and ErrAddressNotAllowed", err)
+	}
+}